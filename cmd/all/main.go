@@ -0,0 +1,74 @@
+// ==============================================================================
+// MONOLITH MAIN - cmd/all/main.go
+// ==============================================================================
+
+// Command all runs the gateway and the services listed in MONOLITH_SERVICES
+// (all of them by default) in one process. The gateway calls the in-process
+// services' routers directly; any service left out of the list is proxied to
+// its *_SERVICE_URL exactly as cmd/gateway does.
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	_ "github.com/lib/pq"
+
+	"kyd/internal/gateway"
+	"kyd/internal/server"
+	"kyd/pkg/bootstrap"
+	"kyd/pkg/metrics"
+	"kyd/pkg/tracing"
+)
+
+func main() {
+	bootstrap.LoadDotEnv()
+	app := bootstrap.New("monolith")
+	cfg, log := app.Config, app.Logger
+
+	app.ConnectDatabase()
+	redisClient := app.ConnectRedis()
+
+	backends, err := gateway.ProxyBackends(cfg)
+	if err != nil {
+		app.Fatal("Failed to configure service proxies", err)
+	}
+
+	services := []struct {
+		name    string
+		build   func(*bootstrap.App) (http.Handler, error)
+		backend *http.Handler
+	}{
+		{"auth", server.Auth, &backends.Auth},
+		{"payment", server.Payment, &backends.Payment},
+		{"wallet", server.Wallet, &backends.Wallet},
+		{"forex", server.Forex, &backends.Forex},
+		{"settlement", server.Settlement, &backends.Settlement},
+	}
+	for _, svc := range services {
+		if !cfg.Monolith.Runs(svc.name) {
+			log.Info("Proxying service", map[string]interface{}{"service": svc.name})
+			continue
+		}
+		h, err := svc.build(app)
+		if err != nil {
+			app.Fatal("Failed to start "+svc.name+" service", err)
+		}
+		*svc.backend = gateway.LocalBackend(h)
+		log.Info("Running service in-process", map[string]interface{}{"service": svc.name})
+	}
+
+	gw := gateway.NewGateway(log, redisClient, cfg, backends)
+
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Use(metrics.Middleware("gateway"))
+	r.HandleFunc("/health", app.Health).Methods("GET")
+	r.HandleFunc("/ready", app.Ready).Methods("GET")
+	r.Handle("/metrics", metrics.Handler(cfg.Metrics)).Methods("GET")
+
+	// Route all other requests through gateway
+	r.PathPrefix("/").Handler(gw)
+
+	app.ServeEdge(r)
+}
@@ -0,0 +1,330 @@
+// Package activity keeps the wallet activity feed: one denormalized row per
+// wallet a transaction touched, holding what the wallet owner's history
+// shows. GET /wallets/{id}/history reads a page of it from a single index
+// instead of joining transactions to users and wallets per request.
+//
+// Rows are written from the stream of transaction changes. The services
+// that store transactions project each one as they store it, so a payment
+// is in both parties' history when the request returns. A catch-up pass
+// then walks transactions by last change to pick up what was written
+// elsewhere (ledger postings, batch settlement updates, recovery) and to
+// backfill an empty feed.
+package activity
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// catchUpBatch is how many changed transactions one query of a catch-up
+// pass reads.
+const catchUpBatch = 500
+
+type Store interface {
+	// Upsert must not replace a row with an older version of its
+	// transaction.
+	Upsert(ctx context.Context, rows []domain.WalletActivity) error
+	FindByWalletAfter(ctx context.Context, walletID uuid.UUID, after *domain.TransactionCursor, limit int) ([]domain.WalletActivity, error)
+	FindByWallet(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]domain.WalletActivity, int, error)
+	// LatestSourceUpdate returns nil while the feed is empty.
+	LatestSourceUpdate(ctx context.Context) (*time.Time, error)
+}
+
+// TransactionSource lists transactions in order of last change.
+type TransactionSource interface {
+	FindChangedSince(ctx context.Context, since time.Time, afterID uuid.UUID, limit int) ([]*domain.Transaction, error)
+}
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+type WalletRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error)
+}
+
+type Projector struct {
+	store   Store
+	users   UserRepository
+	wallets WalletRepository
+	txs     TransactionSource
+	cfg     config.ActivityConfig
+	logger  logger.Logger
+
+	running  sync.Mutex
+	position time.Time
+	// seen holds the UpdatedAt last projected by catch-up for transactions
+	// changed inside the overlap window, so each pass only projects what
+	// changed since the previous one.
+	seen     map[uuid.UUID]time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewProjector(store Store, users UserRepository, wallets WalletRepository, cfg config.ActivityConfig, log logger.Logger) *Projector {
+	return &Projector{
+		store:   store,
+		users:   users,
+		wallets: wallets,
+		cfg:     cfg,
+		logger:  log,
+		seen:    make(map[uuid.UUID]time.Time),
+		stop:    make(chan struct{}),
+	}
+}
+
+// WithCatchUp lets the projector walk txs for changes it was not told
+// about. Only one service needs to run catch-up.
+func (p *Projector) WithCatchUp(txs TransactionSource) *Projector {
+	p.txs = txs
+	return p
+}
+
+// TransactionStored projects tx as its write is reported. A failure is
+// logged and left for catch-up; it never fails the write.
+func (p *Projector) TransactionStored(ctx context.Context, tx *domain.Transaction) {
+	if !p.cfg.Enabled {
+		return
+	}
+	if err := p.Project(ctx, tx); err != nil {
+		p.logger.Error("Failed to project wallet activity", map[string]interface{}{
+			"transaction_id": tx.ID,
+			"error":          err.Error(),
+		})
+	}
+}
+
+// Project writes tx into the history of each wallet it touched.
+func (p *Projector) Project(ctx context.Context, tx *domain.Transaction) error {
+	return p.project(ctx, p.newResolver(), tx)
+}
+
+func (p *Projector) project(ctx context.Context, r *resolver, tx *domain.Transaction) error {
+	rows := p.rows(ctx, r, tx)
+	if len(rows) == 0 {
+		return nil
+	}
+	return p.store.Upsert(ctx, rows)
+}
+
+// rows builds the feed rows for tx. A transaction moving money between two
+// wallets appears in both, as a debit for the sender and a credit, in the
+// received currency, for the receiver. One against a single wallet, such
+// as a top-up or withdrawal, appears once with no counterparty.
+func (p *Projector) rows(ctx context.Context, r *resolver, tx *domain.Transaction) []domain.WalletActivity {
+	base := domain.WalletActivity{
+		TransactionID:   tx.ID,
+		TransactionType: tx.TransactionType,
+		Status:          tx.Status,
+		ReferenceNumber: tx.ReferenceNumber,
+		Description:     tx.Description,
+		OccurredAt:      tx.CreatedAt,
+		SourceUpdatedAt: tx.UpdatedAt,
+	}
+
+	sender, receiver := tx.SenderWalletID, tx.ReceiverWalletID
+	if sender != nil && receiver != nil && *sender == *receiver {
+		row := base
+		row.WalletID = *sender
+		row.Direction = singleWalletDirection(tx.TransactionType)
+		row.Amount, row.Currency, row.FeeAmount = tx.Amount, tx.Currency, tx.FeeAmount
+		return []domain.WalletActivity{row}
+	}
+
+	var rows []domain.WalletActivity
+	if sender != nil {
+		row := base
+		row.WalletID = *sender
+		row.Direction = domain.ActivityDebit
+		row.Amount, row.Currency, row.FeeAmount = tx.Amount, tx.Currency, tx.FeeAmount
+		if receiver != nil {
+			r.counterparty(ctx, &row, tx.ReceiverID, *receiver)
+		}
+		rows = append(rows, row)
+	}
+	if receiver != nil {
+		row := base
+		row.WalletID = *receiver
+		row.Direction = domain.ActivityCredit
+		row.Amount, row.Currency = tx.Amount, tx.Currency
+		if tx.ConvertedCurrency != "" && !tx.ConvertedAmount.IsZero() {
+			row.Amount, row.Currency = tx.ConvertedAmount, tx.ConvertedCurrency
+		}
+		if sender != nil {
+			r.counterparty(ctx, &row, tx.SenderID, *sender)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// singleWalletDirection says which way a transaction against one wallet
+// moved its money.
+func singleWalletDirection(t domain.TransactionType) domain.ActivityDirection {
+	switch t {
+	case domain.TransactionTypeWithdrawal, domain.TransactionTypeEscheatment:
+		return domain.ActivityDebit
+	}
+	return domain.ActivityCredit
+}
+
+// CatchUp projects every transaction changed since the newest change the
+// feed reflects, less the configured overlap, and returns how many it
+// projected. On an empty feed that is every transaction.
+func (p *Projector) CatchUp(ctx context.Context) (int, error) {
+	if p.txs == nil {
+		return 0, nil
+	}
+	p.running.Lock()
+	defer p.running.Unlock()
+
+	latest, err := p.store.LatestSourceUpdate(ctx)
+	if err != nil {
+		return 0, err
+	}
+	position := p.position
+	if latest != nil && latest.After(position) {
+		position = *latest
+	}
+	since := time.Time{}
+	if !position.IsZero() {
+		since = position.Add(-p.cfg.CatchUpOverlap)
+	}
+
+	r := p.newResolver()
+	projected := 0
+	afterID := uuid.Nil
+	for {
+		txs, err := p.txs.FindChangedSince(ctx, since, afterID, catchUpBatch)
+		if err != nil {
+			return projected, err
+		}
+		for _, tx := range txs {
+			if seen, ok := p.seen[tx.ID]; ok && !tx.UpdatedAt.After(seen) {
+				continue
+			}
+			if err := p.project(ctx, r, tx); err != nil {
+				return projected, err
+			}
+			p.seen[tx.ID] = tx.UpdatedAt
+			if tx.UpdatedAt.After(p.position) {
+				p.position = tx.UpdatedAt
+			}
+			projected++
+		}
+		p.forget()
+		if len(txs) < catchUpBatch {
+			return projected, nil
+		}
+		last := txs[len(txs)-1]
+		since, afterID = last.UpdatedAt, last.ID
+	}
+}
+
+// forget drops changes the next pass will not read again, so a backfill
+// does not fill seen.
+func (p *Projector) forget() {
+	horizon := p.position.Add(-p.cfg.CatchUpOverlap)
+	for id, updatedAt := range p.seen {
+		if updatedAt.Before(horizon) {
+			delete(p.seen, id)
+		}
+	}
+}
+
+// Start runs catch-up every configured interval until Stop is called.
+func (p *Projector) Start() {
+	if !p.cfg.Enabled || p.txs == nil {
+		return
+	}
+	ticker := time.NewTicker(p.cfg.CatchUpInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if _, err := p.CatchUp(context.Background()); err != nil {
+					p.logger.Error("Wallet activity catch-up failed", map[string]interface{}{"error": err.Error()})
+				}
+			}
+		}
+	}()
+}
+
+func (p *Projector) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// History returns up to limit of the wallet's activity older than the
+// cursor, newest first, and the cursor of the following page, which is nil
+// once the history is exhausted. A nil cursor starts from the most recent.
+func (p *Projector) History(ctx context.Context, walletID uuid.UUID, after *domain.TransactionCursor, limit int) ([]domain.WalletActivity, *domain.TransactionCursor, error) {
+	rows, err := p.store.FindByWalletAfter(ctx, walletID, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	var next *domain.TransactionCursor
+	if len(rows) == limit && limit > 0 {
+		next = rows[len(rows)-1].Cursor()
+	}
+	return rows, next, nil
+}
+
+// HistoryPage returns a page of the wallet's activity by offset, newest
+// first, and the wallet's total.
+func (p *Projector) HistoryPage(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]domain.WalletActivity, int, error) {
+	return p.store.FindByWallet(ctx, walletID, limit, offset)
+}
+
+// resolver looks up counterparty names and wallet numbers, remembering
+// them for the rest of a projection pass.
+type resolver struct {
+	users   UserRepository
+	wallets WalletRepository
+	names   map[uuid.UUID]string
+	numbers map[uuid.UUID]string
+}
+
+func (p *Projector) newResolver() *resolver {
+	return &resolver{
+		users:   p.users,
+		wallets: p.wallets,
+		names:   make(map[uuid.UUID]string),
+		numbers: make(map[uuid.UUID]string),
+	}
+}
+
+// counterparty fills in the other side of row. Lookups that fail leave the
+// name or number empty rather than holding the projection up.
+func (r *resolver) counterparty(ctx context.Context, row *domain.WalletActivity, userID, walletID uuid.UUID) {
+	if userID != uuid.Nil {
+		id := userID
+		row.CounterpartyID = &id
+		name, ok := r.names[userID]
+		if !ok {
+			if u, err := r.users.FindByID(ctx, userID); err == nil && u != nil {
+				name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+			}
+			r.names[userID] = name
+		}
+		row.CounterpartyName = name
+	}
+	number, ok := r.numbers[walletID]
+	if !ok {
+		if w, err := r.wallets.FindByID(ctx, walletID); err == nil && w != nil && w.WalletAddress != nil {
+			number = *w.WalletAddress
+		}
+		r.numbers[walletID] = number
+	}
+	row.CounterpartyWalletNumber = number
+}
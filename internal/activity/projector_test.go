@@ -0,0 +1,373 @@
+package activity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) Upsert(ctx context.Context, rows []domain.WalletActivity) error {
+	return m.Called(ctx, rows).Error(0)
+}
+
+func (m *MockStore) FindByWalletAfter(ctx context.Context, walletID uuid.UUID, after *domain.TransactionCursor, limit int) ([]domain.WalletActivity, error) {
+	args := m.Called(ctx, walletID, after, limit)
+	rows, _ := args.Get(0).([]domain.WalletActivity)
+	return rows, args.Error(1)
+}
+
+func (m *MockStore) FindByWallet(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]domain.WalletActivity, int, error) {
+	args := m.Called(ctx, walletID, limit, offset)
+	rows, _ := args.Get(0).([]domain.WalletActivity)
+	return rows, args.Int(1), args.Error(2)
+}
+
+func (m *MockStore) LatestSourceUpdate(ctx context.Context) (*time.Time, error) {
+	args := m.Called(ctx)
+	latest, _ := args.Get(0).(*time.Time)
+	return latest, args.Error(1)
+}
+
+type MockTransactions struct {
+	mock.Mock
+}
+
+func (m *MockTransactions) FindChangedSince(ctx context.Context, since time.Time, afterID uuid.UUID, limit int) ([]*domain.Transaction, error) {
+	args := m.Called(ctx, since, afterID, limit)
+	txs, _ := args.Get(0).([]*domain.Transaction)
+	return txs, args.Error(1)
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	u, _ := args.Get(0).(*domain.User)
+	return u, args.Error(1)
+}
+
+type MockWallets struct {
+	mock.Mock
+}
+
+func (m *MockWallets) FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	args := m.Called(ctx, id)
+	w, _ := args.Get(0).(*domain.Wallet)
+	return w, args.Error(1)
+}
+
+type mocks struct {
+	store   *MockStore
+	txs     *MockTransactions
+	users   *MockUsers
+	wallets *MockWallets
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.store.AssertExpectations(t)
+	m.txs.AssertExpectations(t)
+	m.users.AssertExpectations(t)
+	m.wallets.AssertExpectations(t)
+}
+
+// counterparties expects each party and wallet of a payment to be looked up
+// once.
+func (m *mocks) counterparties() {
+	m.users.On("FindByID", ctx, alice.ID).Return(alice, nil).Once()
+	m.users.On("FindByID", ctx, bob.ID).Return(bob, nil).Once()
+	m.wallets.On("FindByID", ctx, aliceMWK.ID).Return(aliceMWK, nil).Once()
+	m.wallets.On("FindByID", ctx, bobCNY.ID).Return(bobCNY, nil).Once()
+}
+
+var (
+	ctx         = context.Background()
+	start       = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	overlap     = time.Minute
+	alice       = &domain.User{ID: uuid.New(), FirstName: "Alice", LastName: "Banda"}
+	bob         = &domain.User{ID: uuid.New(), FirstName: "Bob", LastName: "Li"}
+	aliceNumber = "1111222233334444"
+	bobNumber   = "5555666677778888"
+	aliceMWK    = &domain.Wallet{ID: uuid.New(), UserID: alice.ID, Currency: domain.MWK, WalletAddress: &aliceNumber}
+	bobCNY      = &domain.Wallet{ID: uuid.New(), UserID: bob.ID, Currency: domain.CNY, WalletAddress: &bobNumber}
+)
+
+func newProjector() (*Projector, *mocks) {
+	m := &mocks{new(MockStore), new(MockTransactions), new(MockUsers), new(MockWallets)}
+	cfg := config.ActivityConfig{Enabled: true, CatchUpInterval: time.Second, CatchUpOverlap: overlap}
+	return NewProjector(m.store, m.users, m.wallets, cfg, logger.NewNop()).WithCatchUp(m.txs), m
+}
+
+// payment is Alice paying Bob 10,000 MWK, received as 41 CNY, last changed
+// at at.
+func payment(at time.Time) *domain.Transaction {
+	return &domain.Transaction{
+		ID:                uuid.New(),
+		ReferenceNumber:   "KYD-ABC-123",
+		SenderID:          alice.ID,
+		ReceiverID:        bob.ID,
+		SenderWalletID:    &aliceMWK.ID,
+		ReceiverWalletID:  &bobCNY.ID,
+		Amount:            decimal.NewFromInt(10000),
+		Currency:          domain.MWK,
+		ConvertedAmount:   decimal.NewFromInt(41),
+		ConvertedCurrency: domain.CNY,
+		FeeAmount:         decimal.NewFromInt(150),
+		Status:            domain.TransactionStatusPending,
+		TransactionType:   domain.TransactionTypePayment,
+		Description:       "Rent",
+		CreatedAt:         at,
+		UpdatedAt:         at,
+	}
+}
+
+// projects matches an upsert of the rows for tx.
+func projects(tx *domain.Transaction) interface{} {
+	return mock.MatchedBy(func(rows []domain.WalletActivity) bool {
+		return len(rows) > 0 && rows[0].TransactionID == tx.ID
+	})
+}
+
+func TestTransactionStored_ProjectsBothSides(t *testing.T) {
+	p, m := newProjector()
+	tx := payment(start)
+	m.counterparties()
+	var rows []domain.WalletActivity
+	m.store.On("Upsert", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		rows = args.Get(1).([]domain.WalletActivity)
+	}).Return(nil).Once()
+
+	p.TransactionStored(ctx, tx)
+	m.assert(t)
+
+	require.Len(t, rows, 2)
+	debit := rows[0]
+	assert.Equal(t, aliceMWK.ID, debit.WalletID)
+	assert.Equal(t, domain.ActivityDebit, debit.Direction)
+	assert.True(t, debit.Amount.Equal(decimal.NewFromInt(10000)))
+	assert.Equal(t, domain.MWK, debit.Currency)
+	assert.True(t, debit.FeeAmount.Equal(decimal.NewFromInt(150)))
+	assert.Equal(t, "Bob Li", debit.CounterpartyName)
+	assert.Equal(t, bobNumber, debit.CounterpartyWalletNumber)
+	require.NotNil(t, debit.CounterpartyID)
+	assert.Equal(t, bob.ID, *debit.CounterpartyID)
+	assert.Equal(t, start, debit.SourceUpdatedAt)
+
+	credit := rows[1]
+	assert.Equal(t, bobCNY.ID, credit.WalletID)
+	assert.Equal(t, domain.ActivityCredit, credit.Direction)
+	assert.True(t, credit.Amount.Equal(decimal.NewFromInt(41)), "in the received currency")
+	assert.Equal(t, domain.CNY, credit.Currency)
+	assert.True(t, credit.FeeAmount.IsZero(), "the sender paid the fee")
+	assert.Equal(t, "Alice Banda", credit.CounterpartyName)
+	assert.Equal(t, aliceNumber, credit.CounterpartyWalletNumber)
+}
+
+func TestTransactionStored_SingleWallet(t *testing.T) {
+	tests := []struct {
+		txType domain.TransactionType
+		want   domain.ActivityDirection
+	}{
+		{domain.TransactionTypeDeposit, domain.ActivityCredit},
+		{domain.TransactionTypeWithdrawal, domain.ActivityDebit},
+		{domain.TransactionTypeEscheatment, domain.ActivityDebit},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.txType), func(t *testing.T) {
+			p, m := newProjector()
+			tx := &domain.Transaction{
+				ID: uuid.New(), SenderID: alice.ID, ReceiverID: alice.ID,
+				SenderWalletID: &aliceMWK.ID, ReceiverWalletID: &aliceMWK.ID,
+				Amount: decimal.NewFromInt(500), Currency: domain.MWK,
+				Status: domain.TransactionStatusCompleted, TransactionType: tt.txType,
+				CreatedAt: start, UpdatedAt: start,
+			}
+			m.store.On("Upsert", ctx, mock.MatchedBy(func(rows []domain.WalletActivity) bool {
+				return len(rows) == 1 && rows[0].WalletID == aliceMWK.ID && rows[0].Direction == tt.want &&
+					rows[0].CounterpartyID == nil && rows[0].CounterpartyName == ""
+			})).Return(nil).Once()
+
+			p.TransactionStored(ctx, tx)
+			m.assert(t)
+			m.users.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestTransactionStored_LookupFailuresLeaveCounterpartyEmpty(t *testing.T) {
+	p, m := newProjector()
+	tx := payment(start)
+	m.users.On("FindByID", ctx, mock.Anything).Return(nil, assert.AnError).Twice()
+	m.wallets.On("FindByID", ctx, mock.Anything).Return(nil, assert.AnError).Twice()
+	m.store.On("Upsert", ctx, mock.MatchedBy(func(rows []domain.WalletActivity) bool {
+		return len(rows) == 2 && rows[0].CounterpartyName == "" && rows[0].CounterpartyWalletNumber == "" &&
+			*rows[0].CounterpartyID == bob.ID
+	})).Return(nil).Once()
+
+	p.TransactionStored(ctx, tx)
+	m.assert(t)
+}
+
+func TestTransactionStored_StoreFailureIsNotReturned(t *testing.T) {
+	p, m := newProjector()
+	tx := payment(start)
+	m.counterparties()
+	m.store.On("Upsert", ctx, projects(tx)).Return(assert.AnError).Once()
+
+	assert.NotPanics(t, func() { p.TransactionStored(ctx, tx) }, "left for catch-up")
+	m.assert(t)
+
+	p, m = newProjector()
+	m.counterparties()
+	m.store.On("Upsert", ctx, projects(tx)).Return(assert.AnError).Once()
+	assert.ErrorIs(t, p.Project(ctx, tx), assert.AnError)
+}
+
+func TestTransactionStored_Disabled(t *testing.T) {
+	p, m := newProjector()
+	p.cfg.Enabled = false
+
+	p.TransactionStored(ctx, payment(start))
+
+	m.store.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+}
+
+func TestCatchUp_BackfillsInBatches(t *testing.T) {
+	p, m := newProjector()
+	old := start.Add(-time.Hour)
+	first := make([]*domain.Transaction, catchUpBatch)
+	for i := range first {
+		first[i] = payment(old.Add(time.Duration(i) * time.Millisecond))
+	}
+	last := first[len(first)-1]
+	rest := []*domain.Transaction{payment(start), payment(start)}
+
+	m.store.On("LatestSourceUpdate", ctx).Return(nil, nil).Once()
+	m.txs.On("FindChangedSince", ctx, time.Time{}, uuid.Nil, catchUpBatch).Return(first, nil).Once()
+	m.txs.On("FindChangedSince", ctx, last.UpdatedAt, last.ID, catchUpBatch).Return(rest, nil).Once()
+	m.counterparties()
+	m.store.On("Upsert", ctx, mock.Anything).Return(nil).Times(catchUpBatch + 2)
+
+	n, err := p.CatchUp(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, catchUpBatch+2, n)
+	m.assert(t)
+	assert.Len(t, p.seen, 2, "the backfill is forgotten but for the overlap")
+}
+
+func TestCatchUp_FollowsChanges(t *testing.T) {
+	p, m := newProjector()
+	projected := payment(start)
+	m.store.On("LatestSourceUpdate", ctx).Return(nil, nil).Once()
+	m.txs.On("FindChangedSince", ctx, time.Time{}, uuid.Nil, catchUpBatch).Return([]*domain.Transaction{projected}, nil).Once()
+	m.counterparties()
+	m.store.On("Upsert", ctx, projects(projected)).Return(nil).Once()
+	_, err := p.CatchUp(ctx)
+	require.NoError(t, err)
+	m.assert(t)
+
+	// A posting made outside the repository bumps updated_at only, and a
+	// late commit is stamped before the newest change already seen.
+	latest := start
+	changed := *projected
+	changed.Status, changed.UpdatedAt = domain.TransactionStatusCompleted, start.Add(time.Second)
+	late := payment(start.Add(-30 * time.Second))
+	p, m = reuse(p)
+	m.store.On("LatestSourceUpdate", ctx).Return(&latest, nil).Once()
+	m.txs.On("FindChangedSince", ctx, start.Add(-overlap), uuid.Nil, catchUpBatch).
+		Return([]*domain.Transaction{late, projected, &changed}, nil).Once()
+	m.counterparties()
+	m.store.On("Upsert", ctx, projects(late)).Return(nil).Once()
+	m.store.On("Upsert", ctx, mock.MatchedBy(func(rows []domain.WalletActivity) bool {
+		return rows[0].TransactionID == changed.ID && rows[0].Status == domain.TransactionStatusCompleted
+	})).Return(nil).Once()
+
+	n, err := p.CatchUp(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "the unchanged version is not projected again")
+	m.assert(t)
+
+	// The pass went past what the feed reports, so it reads on from there.
+	p, m = reuse(p)
+	m.store.On("LatestSourceUpdate", ctx).Return(&latest, nil).Once()
+	m.txs.On("FindChangedSince", ctx, start.Add(time.Second-overlap), uuid.Nil, catchUpBatch).
+		Return([]*domain.Transaction{late, &changed}, nil).Once()
+
+	n, err = p.CatchUp(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	m.assert(t)
+	m.store.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+}
+
+// reuse gives p, keeping its position, fresh mocks.
+func reuse(p *Projector) (*Projector, *mocks) {
+	m := &mocks{new(MockStore), new(MockTransactions), new(MockUsers), new(MockWallets)}
+	p.store, p.txs, p.users, p.wallets = m.store, m.txs, m.users, m.wallets
+	return p, m
+}
+
+func TestCatchUp_StopsAtTheFirstFailure(t *testing.T) {
+	p, m := newProjector()
+	ok, failing := payment(start), payment(start.Add(time.Second))
+	m.store.On("LatestSourceUpdate", ctx).Return(nil, nil).Once()
+	m.txs.On("FindChangedSince", ctx, time.Time{}, uuid.Nil, catchUpBatch).Return([]*domain.Transaction{ok, failing}, nil).Once()
+	m.counterparties()
+	m.store.On("Upsert", ctx, projects(ok)).Return(nil).Once()
+	m.store.On("Upsert", ctx, projects(failing)).Return(assert.AnError).Once()
+
+	n, err := p.CatchUp(ctx)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, start, p.position, "the failed change is read again next pass")
+	m.assert(t)
+}
+
+func TestHistory_Cursor(t *testing.T) {
+	p, m := newProjector()
+	rows := make([]domain.WalletActivity, 3)
+	for i := range rows {
+		rows[i] = domain.WalletActivity{WalletID: aliceMWK.ID, TransactionID: uuid.New(), OccurredAt: start.Add(-time.Duration(i) * time.Minute)}
+	}
+	cursor := rows[2].Cursor()
+	m.store.On("FindByWalletAfter", ctx, aliceMWK.ID, (*domain.TransactionCursor)(nil), 3).Return(rows, nil).Once()
+	m.store.On("FindByWalletAfter", ctx, aliceMWK.ID, cursor, 3).Return(rows[:2], nil).Once()
+
+	first, next, err := p.History(ctx, aliceMWK.ID, nil, 3)
+	require.NoError(t, err)
+	assert.Equal(t, rows, first)
+	assert.Equal(t, cursor, next, "a full page may have more after it")
+
+	second, next, err := p.History(ctx, aliceMWK.ID, cursor, 3)
+	require.NoError(t, err)
+	assert.Len(t, second, 2)
+	assert.Nil(t, next)
+	m.assert(t)
+}
+
+func TestHistoryPage(t *testing.T) {
+	p, m := newProjector()
+	rows := []domain.WalletActivity{{WalletID: bobCNY.ID, TransactionID: uuid.New()}}
+	m.store.On("FindByWallet", ctx, bobCNY.ID, 2, 4).Return(rows, 5, nil).Once()
+
+	page, total, err := p.HistoryPage(ctx, bobCNY.ID, 2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	assert.Equal(t, rows, page)
+	m.assert(t)
+}
@@ -0,0 +1,123 @@
+// Package aml screens customers against sanctions and politically exposed
+// person (PEP) lists.
+package aml
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+)
+
+// ErrListsNotLoaded is returned when no list has been loaded yet. Callers
+// must treat it as "not screened", never as "clear".
+var ErrListsNotLoaded = errors.New("screening lists are not loaded")
+
+// DefaultThreshold is the lowest name score counted as a match.
+const DefaultThreshold = 0.88
+
+// ListKind says what being on a list means.
+type ListKind string
+
+const (
+	KindSanction ListKind = "sanction"
+	KindPEP      ListKind = "pep"
+)
+
+// Subject is who is being screened.
+type Subject struct {
+	Name string
+	// Countries are ISO 3166 alpha-2 codes of residence, nationality or the
+	// documents presented.
+	Countries []string
+}
+
+// Match is one list entry a subject's name resembles.
+type Match struct {
+	List      string   `json:"list"`
+	Kind      ListKind `json:"kind"`
+	EntryID   string   `json:"entry_id"`
+	Name      string   `json:"name"`
+	Score     float64  `json:"score"`
+	Programs  []string `json:"programs,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+}
+
+// Result is the outcome of screening one subject.
+type Result struct {
+	Provider    string
+	SanctionHit bool
+	PEP         bool
+	// CountryHit means one of the subject's countries is under
+	// comprehensive sanctions.
+	CountryHit bool
+	// RiskScore runs from 0 (nothing found) to 100 (certain sanctions
+	// match).
+	RiskScore int
+	Matches   []Match
+}
+
+// Provider screens subjects.
+type Provider interface {
+	Name() string
+	Screen(ctx context.Context, s Subject) (*Result, error)
+}
+
+// Risk contributed by each kind of finding.
+const (
+	pepRisk     = 60
+	countryRisk = 80
+)
+
+// assess turns the matches found for s into a Result.
+func assess(provider string, s Subject, matches []Match, sanctionedCountries map[string]bool) *Result {
+	res := &Result{Provider: provider, Matches: matches}
+	for _, m := range matches {
+		switch m.Kind {
+		case KindSanction:
+			res.SanctionHit = true
+			res.RiskScore = max(res.RiskScore, int(math.Round(m.Score*100)))
+		case KindPEP:
+			res.PEP = true
+			res.RiskScore = max(res.RiskScore, pepRisk)
+		}
+	}
+	for _, c := range s.Countries {
+		if sanctionedCountries[strings.ToUpper(c)] {
+			res.CountryHit = true
+			res.RiskScore = max(res.RiskScore, countryRisk)
+		}
+	}
+	if res.Matches == nil {
+		res.Matches = []Match{}
+	}
+	return res
+}
+
+// countrySet builds a lookup of upper-cased country codes.
+func countrySet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, c := range codes {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			set[c] = true
+		}
+	}
+	return set
+}
+
+// mockIndex holds the only names Mock flags.
+var mockIndex = NewIndex([]Entry{
+	{List: "MOCK", Kind: KindSanction, ID: "MOCK-1", Names: []string{"Sanctioned Test Person"}, Programs: []string{"TEST"}},
+	{List: "MOCK", Kind: KindPEP, ID: "MOCK-2", Names: []string{"Exposed Test Person"}},
+})
+
+// Mock flags only "Sanctioned Test Person" (sanctions) and "Exposed Test
+// Person" (PEP). It stands in for real lists in local and test
+// environments.
+type Mock struct{}
+
+func (Mock) Name() string { return "mock" }
+
+func (Mock) Screen(_ context.Context, s Subject) (*Result, error) {
+	return assess("mock", s, mockIndex.Search(s, DefaultThreshold), nil), nil
+}
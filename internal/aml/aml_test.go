@@ -0,0 +1,235 @@
+package aml
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kyd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sdnCSV = `36,"AEROCARIBBEAN AIRLINES",-0- ,"CUBA",-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,"Havana, Cuba."
+7140,"PUTIN, Vladimir Vladimirovich","individual","RUSSIA-EO14024] [UKRAINE-EO13660",-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,"DOB 07 Oct 1952."
+` + "\x1a"
+
+const altCSV = `7140,9001,"aka","PUTIN, Vladimir",-0-
+`
+
+const unXML = `<?xml version="1.0" encoding="UTF-8"?>
+<CONSOLIDATED_LIST>
+  <INDIVIDUALS>
+    <INDIVIDUAL>
+      <DATAID>6908555</DATAID>
+      <FIRST_NAME>ABDUL RAHMAN</FIRST_NAME>
+      <SECOND_NAME>YASIN</SECOND_NAME>
+      <UN_LIST_TYPE>Al-Qaida</UN_LIST_TYPE>
+      <NATIONALITY><VALUE>Iraq</VALUE></NATIONALITY>
+      <INDIVIDUAL_ALIAS><QUALITY>Good</QUALITY><ALIAS_NAME>Abdul Rahman Said Yasin</ALIAS_NAME></INDIVIDUAL_ALIAS>
+    </INDIVIDUAL>
+  </INDIVIDUALS>
+  <ENTITIES>
+    <ENTITY>
+      <DATAID>110</DATAID>
+      <FIRST_NAME>AL-HARAMAIN FOUNDATION</FIRST_NAME>
+      <UN_LIST_TYPE>Al-Qaida</UN_LIST_TYPE>
+    </ENTITY>
+  </ENTITIES>
+</CONSOLIDATED_LIST>`
+
+const euXML = `<?xml version="1.0" encoding="UTF-8"?>
+<export xmlns="http://eu.europa.ec/fpi/fsd/export">
+  <sanctionEntity logicalId="13" euReferenceNumber="EU.27.28">
+    <regulation programme="IRQ"/>
+    <subjectType code="person"/>
+    <nameAlias wholeName="Saddam Hussein Al-Tikriti"/>
+    <nameAlias wholeName="Abu Ali"/>
+    <citizenship countryIso2Code="IQ"/>
+  </sanctionEntity>
+</export>`
+
+const pepCSV = `id,name,aliases,countries
+p1,José Müller,Jose Mueller,MW;ZA
+`
+
+func TestNameScore(t *testing.T) {
+	score := func(a, b string) float64 { return nameScore(tokens(a), tokens(b)) }
+
+	assert.Equal(t, 1.0, score("Vladimir Putin", "PUTIN, Vladimir"))
+	assert.GreaterOrEqual(t, score("Vladimir Putin", "Vladimir Vladimirovich PUTIN"), DefaultThreshold)
+	assert.GreaterOrEqual(t, score("Vladimr Puttin", "Vladimir Putin"), DefaultThreshold)
+	assert.GreaterOrEqual(t, score("Abdulrahman Yasin", "ABDUL RAHMAN YASIN"), DefaultThreshold)
+	assert.Equal(t, 1.0, score("Jose Muller", "José Müller"))
+
+	assert.Less(t, score("John Smith", "Vladimir Putin"), DefaultThreshold)
+	assert.Less(t, score("Putin", "Vladimir Putin"), DefaultThreshold, "a surname alone")
+	assert.Less(t, score("Chikondi Banda", "Chisomo Phiri"), DefaultThreshold)
+}
+
+func TestParseFeeds(t *testing.T) {
+	sdn, err := ParseFeed(FormatOFACSDN, "OFAC", "", strings.NewReader(sdnCSV))
+	require.NoError(t, err)
+	require.Len(t, sdn, 2)
+	assert.Equal(t, "OFAC-7140", sdn[1].ID)
+	assert.Equal(t, []string{"RUSSIA-EO14024", "UKRAINE-EO13660"}, sdn[1].Programs)
+
+	alt, err := ParseFeed(FormatOFACAlt, "OFAC", "", strings.NewReader(altCSV))
+	require.NoError(t, err)
+	ix := NewIndex(append(sdn, alt...))
+	assert.Equal(t, map[string]int{"OFAC": 2}, ix.Counts(), "aliases merge into their SDN entry")
+
+	un, err := ParseFeed(FormatUN, "UN", "", strings.NewReader(unXML))
+	require.NoError(t, err)
+	require.Len(t, un, 2)
+	assert.Equal(t, "ABDUL RAHMAN YASIN", un[0].Names[0])
+	assert.Equal(t, []string{"Iraq"}, un[0].Countries)
+
+	eu, err := ParseFeed(FormatEU, "EU", "", strings.NewReader(euXML))
+	require.NoError(t, err)
+	require.Len(t, eu, 1)
+	assert.Equal(t, []string{"Saddam Hussein Al-Tikriti", "Abu Ali"}, eu[0].Names)
+	assert.Equal(t, []string{"IQ"}, eu[0].Countries)
+	assert.Equal(t, []string{"IRQ"}, eu[0].Programs)
+
+	pep, err := ParseFeed(FormatCSV, "PEP", KindPEP, strings.NewReader(pepCSV))
+	require.NoError(t, err)
+	require.Len(t, pep, 1)
+	assert.Equal(t, KindPEP, pep[0].Kind)
+	assert.Equal(t, []string{"MW", "ZA"}, pep[0].Countries)
+
+	_, err = ParseFeed(FormatCSV, "X", "", strings.NewReader("id,full_name\n1,a\n"))
+	assert.Error(t, err)
+}
+
+// listServer serves each feed body at /<name> and counts requests.
+func listServer(t *testing.T, bodies map[string]string) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, ok := bodies[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestListProviderScreens(t *testing.T) {
+	srv, hits := listServer(t, map[string]string{"sdn": sdnCSV, "alt": altCSV, "un": unXML, "eu": euXML, "pep": pepCSV})
+	cfg := Config{
+		Feeds: []Feed{
+			{Name: "ofac_sdn", List: "OFAC", Format: FormatOFACSDN, URL: srv.URL + "/sdn"},
+			{Name: "ofac_alt", List: "OFAC", Format: FormatOFACAlt, URL: srv.URL + "/alt"},
+			{Name: "un", List: "UN", Format: FormatUN, URL: srv.URL + "/un"},
+			{Name: "eu", List: "EU", Format: FormatEU, URL: srv.URL + "/eu"},
+			{Name: "pep", List: "PEP", Format: FormatCSV, Kind: KindPEP, URL: srv.URL + "/pep"},
+		},
+		CacheDir:            t.TempDir(),
+		SanctionedCountries: []string{"KP", "IR"},
+	}
+	p := NewListProvider(cfg, logger.NewNop())
+	ctx := context.Background()
+
+	_, err := p.Screen(ctx, Subject{Name: "anyone"})
+	assert.ErrorIs(t, err, ErrListsNotLoaded, "nobody is cleared before the lists load")
+
+	require.NoError(t, p.Refresh(ctx))
+	_, counts := p.Status()
+	assert.Equal(t, map[string]int{"OFAC": 2, "UN": 2, "EU": 1, "PEP": 1}, counts)
+
+	res, err := p.Screen(ctx, Subject{Name: "Vladimir Putin", Countries: []string{"RU"}})
+	require.NoError(t, err)
+	assert.True(t, res.SanctionHit)
+	assert.False(t, res.PEP)
+	assert.GreaterOrEqual(t, res.RiskScore, 88)
+	require.NotEmpty(t, res.Matches)
+	assert.Equal(t, "OFAC-7140", res.Matches[0].EntryID)
+
+	res, err = p.Screen(ctx, Subject{Name: "Jose Muller", Countries: []string{"MW"}})
+	require.NoError(t, err)
+	assert.False(t, res.SanctionHit)
+	assert.True(t, res.PEP)
+	assert.Equal(t, pepRisk, res.RiskScore)
+
+	res, err = p.Screen(ctx, Subject{Name: "Chikondi Banda", Countries: []string{"ir"}})
+	require.NoError(t, err)
+	assert.False(t, res.SanctionHit)
+	assert.True(t, res.CountryHit)
+	assert.Equal(t, countryRisk, res.RiskScore)
+	assert.Empty(t, res.Matches)
+
+	// Fresh caches are not downloaded again.
+	before := hits.Load()
+	require.NoError(t, p.Refresh(ctx))
+	assert.Equal(t, before, hits.Load())
+}
+
+func TestListProviderFallsBackToStaleCache(t *testing.T) {
+	srv, _ := listServer(t, map[string]string{"sdn": sdnCSV})
+	cfg := Config{
+		Feeds:           []Feed{{Name: "ofac_sdn", List: "OFAC", Format: FormatOFACSDN, URL: srv.URL + "/sdn"}},
+		CacheDir:        t.TempDir(),
+		RefreshInterval: time.Hour,
+	}
+	p := NewListProvider(cfg, logger.NewNop())
+	ctx := context.Background()
+	require.NoError(t, p.Refresh(ctx))
+
+	// The publisher goes down and the cache goes stale: screening carries
+	// on with what was last downloaded.
+	srv.Close()
+	p.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	require.NoError(t, p.Refresh(ctx))
+	res, err := p.Screen(ctx, Subject{Name: "Aerocaribbean Airlines"})
+	require.NoError(t, err)
+	assert.True(t, res.SanctionHit)
+
+	// With no cache at all, the feed cannot load.
+	fresh := NewListProvider(Config{Feeds: cfg.Feeds, CacheDir: t.TempDir()}, logger.NewNop())
+	assert.Error(t, fresh.Refresh(ctx))
+	_, err = fresh.Screen(ctx, Subject{Name: "x"})
+	assert.ErrorIs(t, err, ErrListsNotLoaded)
+}
+
+func TestDownloadKeepsCacheOnBadFile(t *testing.T) {
+	body := sdnCSV
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }))
+	defer srv.Close()
+	dir := t.TempDir()
+	p := NewListProvider(Config{
+		Feeds:           []Feed{{Name: "un", List: "UN", Format: FormatUN, URL: srv.URL}},
+		CacheDir:        dir,
+		RefreshInterval: time.Hour,
+	}, logger.NewNop())
+
+	body = unXML
+	require.NoError(t, p.Refresh(context.Background()))
+	body = "<html>maintenance</html>"
+	p.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	require.NoError(t, p.Refresh(context.Background()))
+	cached, err := os.ReadFile(p.cachePath(p.cfg.Feeds[0]))
+	require.NoError(t, err)
+	assert.Equal(t, unXML, string(cached))
+}
+
+func TestMock(t *testing.T) {
+	res, err := Mock{}.Screen(context.Background(), Subject{Name: "Sanctioned Test Person"})
+	require.NoError(t, err)
+	assert.True(t, res.SanctionHit)
+	res, err = Mock{}.Screen(context.Background(), Subject{Name: "Exposed Test Person"})
+	require.NoError(t, err)
+	assert.True(t, res.PEP)
+	res, err = Mock{}.Screen(context.Background(), Subject{Name: "Ordinary Customer"})
+	require.NoError(t, err)
+	assert.Zero(t, res.RiskScore)
+}
@@ -0,0 +1,135 @@
+package aml
+
+import (
+	"sort"
+	"strings"
+)
+
+// Entry is one listed person or organisation.
+type Entry struct {
+	List      string
+	Kind      ListKind
+	ID        string
+	Names     []string
+	Countries []string
+	Programs  []string
+}
+
+// maxMatches caps how many matches one screening reports.
+const maxMatches = 10
+
+// countryBonus is added to the score of a match whose listed countries
+// include one of the subject's.
+const countryBonus = 0.03
+
+type indexedEntry struct {
+	Entry
+	tokens [][]string
+}
+
+// Index is a searchable set of list entries. Entries sharing a list and ID,
+// like an OFAC SDN record and its aliases, are merged.
+type Index struct {
+	entries []*indexedEntry
+	counts  map[string]int
+}
+
+func NewIndex(entries []Entry) *Index {
+	ix := &Index{counts: map[string]int{}}
+	byKey := map[string]*indexedEntry{}
+	for _, e := range entries {
+		key := e.List + "\x00" + e.ID
+		ie, ok := byKey[key]
+		if !ok {
+			ie = &indexedEntry{Entry: Entry{List: e.List, Kind: e.Kind, ID: e.ID}}
+			byKey[key] = ie
+			ix.entries = append(ix.entries, ie)
+			ix.counts[e.List]++
+		}
+		if ie.Kind == "" {
+			ie.Kind = e.Kind
+		}
+		ie.Countries = appendNew(ie.Countries, e.Countries...)
+		ie.Programs = appendNew(ie.Programs, e.Programs...)
+		for _, n := range e.Names {
+			if t := tokens(n); len(t) > 0 && !contains(ie.Names, n) {
+				ie.Names = append(ie.Names, n)
+				ie.tokens = append(ie.tokens, t)
+			}
+		}
+	}
+	return ix
+}
+
+// Counts returns how many entries each list holds.
+func (ix *Index) Counts() map[string]int {
+	out := make(map[string]int, len(ix.counts))
+	for k, v := range ix.counts {
+		out[k] = v
+	}
+	return out
+}
+
+// Search returns the entries whose names score at least threshold against
+// the subject's, best first.
+func (ix *Index) Search(s Subject, threshold float64) []Match {
+	subject := tokens(s.Name)
+	if len(subject) == 0 {
+		return nil
+	}
+	countries := countrySet(s.Countries)
+	var matches []Match
+	for _, e := range ix.entries {
+		best, name := 0.0, ""
+		for i, t := range e.tokens {
+			if score := nameScore(subject, t); score > best {
+				best, name = score, e.Names[i]
+			}
+		}
+		if best < threshold-countryBonus {
+			continue
+		}
+		for _, c := range e.Countries {
+			if countries[strings.ToUpper(c)] {
+				best = min(1, best+countryBonus)
+				break
+			}
+		}
+		if best < threshold {
+			continue
+		}
+		matches = append(matches, Match{
+			List:      e.List,
+			Kind:      e.Kind,
+			EntryID:   e.ID,
+			Name:      name,
+			Score:     float64(int(best*1000+0.5)) / 1000,
+			Programs:  e.Programs,
+			Countries: e.Countries,
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > maxMatches {
+		matches = matches[:maxMatches]
+	}
+	return matches
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// appendNew appends the non-empty values not already in list.
+func appendNew(list []string, values ...string) []string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" && !contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,269 @@
+package aml
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the layout of a list feed.
+type Format string
+
+const (
+	// FormatOFACSDN is OFAC's SDN.CSV: ent_num, name, type, program, ...
+	FormatOFACSDN Format = "ofac_sdn"
+	// FormatOFACAlt is OFAC's ALT.CSV of aliases: ent_num, alt_num, type,
+	// name, ...
+	FormatOFACAlt Format = "ofac_alt"
+	// FormatUN is the UN Security Council consolidated list XML.
+	FormatUN Format = "un"
+	// FormatEU is the EU financial sanctions files (FSF) XML.
+	FormatEU Format = "eu"
+	// FormatCSV is a CSV with a header naming at least a "name" column and
+	// optionally "id", "aliases", "countries" and "programs"; lists within
+	// a field are separated by ";". OpenSanctions' simple CSV exports,
+	// including its PEP lists, use this layout.
+	FormatCSV Format = "csv"
+)
+
+// ParseFeed reads the entries of a feed in the given format, labelling
+// them with list and, for FormatCSV, kind; the official sanctions formats
+// are always sanctions.
+func ParseFeed(format Format, list string, kind ListKind, r io.Reader) ([]Entry, error) {
+	switch format {
+	case FormatOFACSDN:
+		return parseOFAC(r, list, 1, 3)
+	case FormatOFACAlt:
+		return parseOFAC(r, list, 3, -1)
+	case FormatUN:
+		return parseUN(r, list)
+	case FormatEU:
+		return parseEU(r, list)
+	case FormatCSV:
+		if kind == "" {
+			kind = KindSanction
+		}
+		return parseCSV(r, list, kind)
+	}
+	return nil, fmt.Errorf("unknown list format %q", format)
+}
+
+// ofacNull is how OFAC files mark an empty field.
+const ofacNull = "-0-"
+
+// parseOFAC reads SDN.CSV or ALT.CSV, which have no header; nameCol and
+// programCol (-1 for none) pick the columns to use.
+func parseOFAC(r io.Reader, list string, nameCol, programCol int) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	var entries []Entry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", list, err)
+		}
+		// The files end with a lone EOF (0x1A) character.
+		if len(rec) <= nameCol || len(rec) <= programCol {
+			continue
+		}
+		name := strings.TrimSpace(rec[nameCol])
+		if name == "" || name == ofacNull {
+			continue
+		}
+		e := Entry{List: list, Kind: KindSanction, ID: list + "-" + strings.TrimSpace(rec[0]), Names: []string{name}}
+		if programCol >= 0 {
+			for _, p := range strings.Split(rec[programCol], "] [") {
+				if p = strings.Trim(p, "[] "); p != "" && p != ofacNull {
+					e.Programs = append(e.Programs, p)
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+type unIndividual struct {
+	DataID      string   `xml:"DATAID"`
+	First       string   `xml:"FIRST_NAME"`
+	Second      string   `xml:"SECOND_NAME"`
+	Third       string   `xml:"THIRD_NAME"`
+	Fourth      string   `xml:"FOURTH_NAME"`
+	ListType    string   `xml:"UN_LIST_TYPE"`
+	Nationality []string `xml:"NATIONALITY>VALUE"`
+	Aliases     []string `xml:"INDIVIDUAL_ALIAS>ALIAS_NAME"`
+}
+
+type unEntity struct {
+	DataID   string   `xml:"DATAID"`
+	Name     string   `xml:"FIRST_NAME"`
+	ListType string   `xml:"UN_LIST_TYPE"`
+	Aliases  []string `xml:"ENTITY_ALIAS>ALIAS_NAME"`
+}
+
+func parseUN(r io.Reader, list string) ([]Entry, error) {
+	var entries []Entry
+	err := eachElement(r, func(d *xml.Decoder, start xml.StartElement) error {
+		switch start.Name.Local {
+		case "INDIVIDUAL":
+			var v unIndividual
+			if err := d.DecodeElement(&v, &start); err != nil {
+				return err
+			}
+			name := strings.Join(strings.Fields(strings.Join([]string{v.First, v.Second, v.Third, v.Fourth}, " ")), " ")
+			entries = append(entries, Entry{
+				List: list, Kind: KindSanction, ID: list + "-" + v.DataID,
+				Names:     append([]string{name}, v.Aliases...),
+				Countries: v.Nationality,
+				Programs:  nonEmpty(v.ListType),
+			})
+		case "ENTITY":
+			var v unEntity
+			if err := d.DecodeElement(&v, &start); err != nil {
+				return err
+			}
+			entries = append(entries, Entry{
+				List: list, Kind: KindSanction, ID: list + "-" + v.DataID,
+				Names:    append([]string{v.Name}, v.Aliases...),
+				Programs: nonEmpty(v.ListType),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", list, err)
+	}
+	return entries, nil
+}
+
+type euEntity struct {
+	LogicalID   string `xml:"logicalId,attr"`
+	Regulations []struct {
+		Programme string `xml:"programme,attr"`
+	} `xml:"regulation"`
+	Names []struct {
+		WholeName string `xml:"wholeName,attr"`
+	} `xml:"nameAlias"`
+	Citizenships []struct {
+		Country string `xml:"countryIso2Code,attr"`
+	} `xml:"citizenship"`
+}
+
+func parseEU(r io.Reader, list string) ([]Entry, error) {
+	var entries []Entry
+	err := eachElement(r, func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name.Local != "sanctionEntity" {
+			return nil
+		}
+		var v euEntity
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		}
+		e := Entry{List: list, Kind: KindSanction, ID: list + "-" + v.LogicalID}
+		for _, n := range v.Names {
+			e.Names = appendNew(e.Names, n.WholeName)
+		}
+		for _, c := range v.Citizenships {
+			// "00" marks an unknown country.
+			if c.Country != "00" {
+				e.Countries = appendNew(e.Countries, c.Country)
+			}
+		}
+		for _, reg := range v.Regulations {
+			e.Programs = appendNew(e.Programs, reg.Programme)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", list, err)
+	}
+	return entries, nil
+}
+
+// eachElement calls fn for every start element in r. fn may consume the
+// element with DecodeElement, so large files are read one record at a time.
+func eachElement(r io.Reader, fn func(*xml.Decoder, xml.StartElement) error) error {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if err := fn(d, start); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseCSV(r io.Reader, list string, kind ListKind) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", list, err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := col["name"]; !ok {
+		return nil, fmt.Errorf("parse %s: no name column", list)
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	var entries []Entry
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", list, err)
+		}
+		name := field(rec, "name")
+		if name == "" {
+			continue
+		}
+		id := field(rec, "id")
+		if id == "" {
+			id = fmt.Sprint(line)
+		}
+		entries = append(entries, Entry{
+			List: list, Kind: kind, ID: list + "-" + id,
+			Names:     append([]string{name}, splitList(field(rec, "aliases"))...),
+			Countries: splitList(field(rec, "countries")),
+			Programs:  splitList(field(rec, "programs")),
+		})
+	}
+	return entries, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func nonEmpty(values ...string) []string {
+	return appendNew(nil, values...)
+}
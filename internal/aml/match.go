@@ -0,0 +1,139 @@
+package aml
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// fold strips the accents most common in listed names, so "Müller" and
+// "Muller" compare equal.
+var fold = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a",
+	"ç", "c", "č", "c", "ć", "c",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e", "ė", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i",
+	"ñ", "n", "ń", "n",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "ō", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ū", "u",
+	"ý", "y", "ÿ", "y",
+	"š", "s", "ś", "s", "ž", "z", "ź", "z", "ż", "z", "ł", "l", "ß", "ss",
+)
+
+// tokens splits a name into lower-case, accent-free words.
+func tokens(name string) []string {
+	s := fold.Replace(strings.ToLower(name))
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameScore rates how alike two tokenised names are, from 0 to 1,
+// regardless of word order. Each word of the shorter name is paired with
+// its closest unused word of the longer one, so a missing middle name costs
+// little, but a single word matched against a full name is marked down.
+func nameScore(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	short, long := a, b
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	used := make([]bool, len(long))
+	var total, weight float64
+	for _, t := range short {
+		best, bi := 0.0, -1
+		for j, u := range long {
+			if used[j] {
+				continue
+			}
+			if s := jaroWinkler(t, u); s > best {
+				best, bi = s, j
+			}
+		}
+		if bi >= 0 {
+			used[bi] = true
+		}
+		n := float64(len([]rune(t)))
+		total += best * n
+		weight += n
+	}
+	score := total / weight
+	if len(short) == 1 && len(long) > 1 {
+		score *= 0.85
+	}
+	// Also compare the names run together, so "Abdul Rahman" still matches
+	// "Abdulrahman"; only for names of about the same length, or a surname
+	// alone would match its owner's full name.
+	ja, jb := sortedJoin(a), sortedJoin(b)
+	if la, lb := len([]rune(ja)), len([]rune(jb)); 5*abs(la-lb) <= max(la, lb) {
+		score = max(score, jaroWinkler(ja, jb))
+	}
+	return score
+}
+
+func sortedJoin(t []string) string {
+	s := append([]string(nil), t...)
+	sort.Strings(s)
+	return strings.Join(s, "")
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// jaroWinkler is the Jaro-Winkler similarity of a and b, from 0 to 1.
+func jaroWinkler(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		lo, hi := max(0, i-window), min(len(rb), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,265 @@
+package aml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+)
+
+// Feed is one downloadable list.
+type Feed struct {
+	// Name identifies the feed and names its cache file.
+	Name string
+	// List labels matches, e.g. "OFAC". Feeds sharing a list, like OFAC's
+	// SDN and ALT files, have their entries merged by ID.
+	List   string
+	Format Format
+	URL    string
+	// Kind applies to FormatCSV feeds; the official formats are sanctions.
+	Kind ListKind
+}
+
+// Config tunes a ListProvider.
+type Config struct {
+	Feeds []Feed
+	// CacheDir keeps the last download of each feed, so screening works
+	// across restarts and through outages of the list publishers.
+	CacheDir string
+	// RefreshInterval is how old a cached feed may get before it is
+	// downloaded again.
+	RefreshInterval time.Duration
+	Threshold       float64
+	// SanctionedCountries are ISO alpha-2 codes under comprehensive
+	// sanctions; subjects tied to them are flagged and scored high.
+	SanctionedCountries []string
+	HTTPTimeout         time.Duration
+}
+
+// ListProvider screens against sanctions and PEP lists downloaded from
+// their publishers and held in memory.
+type ListProvider struct {
+	cfg       Config
+	client    *http.Client
+	logger    logger.Logger
+	countries map[string]bool
+
+	mu       sync.RWMutex
+	index    *Index
+	loadedAt time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	now      func() time.Time
+}
+
+func NewListProvider(cfg Config, log logger.Logger) *ListProvider {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultThreshold
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 24 * time.Hour
+	}
+	if cfg.HTTPTimeout <= 0 {
+		cfg.HTTPTimeout = 2 * time.Minute
+	}
+	return &ListProvider{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: cfg.HTTPTimeout},
+		logger:    log,
+		countries: countrySet(cfg.SanctionedCountries),
+		stop:      make(chan struct{}),
+		now:       time.Now,
+	}
+}
+
+func (p *ListProvider) Name() string { return "sanctions_lists" }
+
+// Screen matches s against the loaded lists.
+func (p *ListProvider) Screen(_ context.Context, s Subject) (*Result, error) {
+	p.mu.RLock()
+	ix := p.index
+	p.mu.RUnlock()
+	if ix == nil {
+		return nil, ErrListsNotLoaded
+	}
+	return assess(p.Name(), s, ix.Search(s, p.cfg.Threshold), p.countries), nil
+}
+
+// Status reports when the lists were last loaded and how many entries each
+// holds.
+func (p *ListProvider) Status() (time.Time, map[string]int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.index == nil {
+		return time.Time{}, map[string]int{}
+	}
+	return p.loadedAt, p.index.Counts()
+}
+
+// Refresh loads every feed, downloading those whose cache is missing or
+// stale, and swaps in the new lists. A feed that cannot be downloaded is
+// read from its stale cache; one with no cache at all is left out. The
+// previous lists are kept if no feed could be loaded.
+func (p *ListProvider) Refresh(ctx context.Context) error {
+	var entries []Entry
+	var errs []error
+	loaded := 0
+	for _, f := range p.cfg.Feeds {
+		e, err := p.load(ctx, f)
+		if err != nil {
+			p.logger.Error("Failed to load screening list", map[string]interface{}{"feed": f.Name, "error": err.Error()})
+			errs = append(errs, err)
+			continue
+		}
+		entries = append(entries, e...)
+		loaded++
+	}
+	if loaded == 0 && len(p.cfg.Feeds) > 0 {
+		return errors.Join(errs...)
+	}
+	ix := NewIndex(entries)
+	p.mu.Lock()
+	p.index, p.loadedAt = ix, p.now()
+	p.mu.Unlock()
+	p.logger.Info("Screening lists loaded", map[string]interface{}{"entries": ix.Counts()})
+	return errors.Join(errs...)
+}
+
+func (p *ListProvider) cachePath(f Feed) string {
+	return filepath.Join(p.cfg.CacheDir, f.Name+".list")
+}
+
+// load parses a feed from its cache, downloading it first if the cache is
+// stale.
+func (p *ListProvider) load(ctx context.Context, f Feed) ([]Entry, error) {
+	path := p.cachePath(f)
+	info, statErr := os.Stat(path)
+	if statErr != nil || p.now().Sub(info.ModTime()) >= p.cfg.RefreshInterval {
+		if err := p.download(ctx, f, path); err != nil {
+			if statErr != nil {
+				return nil, err
+			}
+			p.logger.Warn("Using stale screening list", map[string]interface{}{"feed": f.Name, "error": err.Error(), "cached_at": info.ModTime()})
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseFeed(f.Format, f.List, f.Kind, file)
+}
+
+// download fetches a feed into path, replacing the cache only once the
+// whole file has arrived and parses.
+func (p *ListProvider) download(ctx context.Context, f Feed, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", f.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: status %d", f.Name, resp.StatusCode)
+	}
+	if err := os.MkdirAll(p.cfg.CacheDir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(p.cfg.CacheDir, f.Name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("download %s: %w", f.Name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	check, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	entries, err := ParseFeed(f.Format, f.List, f.Kind, check)
+	check.Close()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("download %s: no entries", f.Name)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Start loads the lists and then refreshes them every RefreshInterval
+// until Stop is called.
+func (p *ListProvider) Start() {
+	go func() {
+		_ = p.Refresh(context.Background())
+		ticker := time.NewTicker(p.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = p.Refresh(context.Background())
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	p.logger.Info("Screening list refresher started", map[string]interface{}{"feeds": len(p.cfg.Feeds)})
+}
+
+func (p *ListProvider) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// ConfigFromConfig builds the provider config from the application config,
+// skipping feeds with no URL.
+func ConfigFromConfig(cfg config.AMLConfig) Config {
+	var feeds []Feed
+	add := func(f Feed) {
+		if f.URL != "" {
+			feeds = append(feeds, f)
+		}
+	}
+	add(Feed{Name: "ofac_sdn", List: "OFAC", Format: FormatOFACSDN, URL: cfg.OFACSDNURL})
+	add(Feed{Name: "ofac_alt", List: "OFAC", Format: FormatOFACAlt, URL: cfg.OFACAltURL})
+	add(Feed{Name: "un", List: "UN", Format: FormatUN, URL: cfg.UNURL})
+	add(Feed{Name: "eu", List: "EU", Format: FormatEU, URL: cfg.EUURL})
+	add(Feed{Name: "pep", List: "PEP", Format: FormatCSV, Kind: KindPEP, URL: cfg.PEPCSVURL})
+	return Config{
+		Feeds:               feeds,
+		CacheDir:            cfg.CacheDir,
+		RefreshInterval:     cfg.RefreshInterval,
+		Threshold:           cfg.MatchThreshold,
+		SanctionedCountries: cfg.SanctionedCountries,
+	}
+}
+
+// FromConfig returns the provider cfg selects, or nil if screening is off.
+// A ListProvider must be started before it can screen anyone.
+func FromConfig(cfg config.AMLConfig, log logger.Logger) (Provider, error) {
+	switch cfg.Provider {
+	case "lists":
+		return NewListProvider(ConfigFromConfig(cfg), log), nil
+	case "mock":
+		return Mock{}, nil
+	case "", "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown aml provider %q", cfg.Provider)
+}
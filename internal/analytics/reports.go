@@ -0,0 +1,266 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+)
+
+var ErrInvalidRange = errors.New("invalid date range")
+
+// ReportRepository stores the daily aggregates behind the funnel and
+// retention reports. The Refresh methods recompute a window from the source
+// tables and are safe to repeat.
+type ReportRepository interface {
+	RefreshFunnel(ctx context.Context, from, to time.Time) error
+	RefreshRetention(ctx context.Context, from, to time.Time) error
+	ListFunnel(ctx context.Context, from, to time.Time, corridor string) ([]domain.FunnelDay, error)
+	ListRetention(ctx context.Context, from, to time.Time, corridor string) ([]domain.RetentionCell, error)
+}
+
+const (
+	refreshInterval = 24 * time.Hour
+	// funnelLookback is how far back registration days are recomputed on
+	// each run; users who registered earlier rarely convert any more and
+	// their rows are left as last computed.
+	funnelLookback = 90
+	// maxReportRange bounds a single report request.
+	maxReportRange = 2 * 366 * 24 * time.Hour
+)
+
+// Reports maintains and serves the precomputed admin analytics.
+type Reports struct {
+	repo   ReportRepository
+	logger logger.Logger
+	now    func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewReports(repo ReportRepository, log logger.Logger) *Reports {
+	return &Reports{
+		repo:   repo,
+		logger: log,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start refreshes the aggregates now and then once a day until Stop.
+func (r *Reports) Start() {
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+			if err := r.Refresh(ctx); err != nil {
+				r.logger.Error("Analytics refresh failed", map[string]interface{}{"error": err.Error()})
+			}
+			cancel()
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *Reports) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// Refresh recomputes the recent window of both aggregates: registration days
+// within the funnel lookback and the current and previous activity months.
+func (r *Reports) Refresh(ctx context.Context) error {
+	today := startOfDay(r.now())
+	tomorrow := today.AddDate(0, 0, 1)
+	if err := r.repo.RefreshFunnel(ctx, today.AddDate(0, 0, -funnelLookback), tomorrow); err != nil {
+		return err
+	}
+	month := startOfMonth(today)
+	if err := r.repo.RefreshRetention(ctx, month.AddDate(0, -1, 0), month.AddDate(0, 1, 0)); err != nil {
+		return err
+	}
+	r.logger.Info("Analytics aggregates refreshed", map[string]interface{}{"day": today.Format("2006-01-02")})
+	return nil
+}
+
+// Rebuild recomputes both aggregates from since up to today, for backfills
+// and after corrections to historical data.
+func (r *Reports) Rebuild(ctx context.Context, since time.Time) error {
+	from := startOfDay(since)
+	to := startOfDay(r.now()).AddDate(0, 0, 1)
+	if !from.Before(to) {
+		return ErrInvalidRange
+	}
+	if err := r.repo.RefreshFunnel(ctx, from, to); err != nil {
+		return err
+	}
+	return r.repo.RefreshRetention(ctx, startOfMonth(from), startOfMonth(to).AddDate(0, 1, 0))
+}
+
+// FunnelStage holds the counts and step conversion rates of one corridor.
+type FunnelStage struct {
+	Corridor     string  `json:"corridor"`
+	Registered   int     `json:"registered"`
+	KYCVerified  int     `json:"kyc_verified"`
+	FirstPayment int     `json:"first_payment"`
+	KYCRate      float64 `json:"kyc_rate"`
+	PaymentRate  float64 `json:"payment_rate"`
+	Conversion   float64 `json:"conversion"`
+}
+
+func (s *FunnelStage) add(d domain.FunnelDay) {
+	s.Registered += d.Registered
+	s.KYCVerified += d.KYCVerified
+	s.FirstPayment += d.FirstPayment
+}
+
+func (s *FunnelStage) rates() {
+	s.KYCRate = ratio(s.KYCVerified, s.Registered)
+	s.PaymentRate = ratio(s.FirstPayment, s.KYCVerified)
+	s.Conversion = ratio(s.FirstPayment, s.Registered)
+}
+
+type FunnelReport struct {
+	From      time.Time          `json:"from"`
+	To        time.Time          `json:"to"`
+	Total     FunnelStage        `json:"total"`
+	Corridors []FunnelStage      `json:"corridors"`
+	Days      []domain.FunnelDay `json:"days"`
+}
+
+// Funnel reports registration→KYC→first payment conversion for users who
+// registered in [from, to), optionally limited to one corridor.
+func (r *Reports) Funnel(ctx context.Context, from, to time.Time, corridor string) (*FunnelReport, error) {
+	if err := checkRange(from, to); err != nil {
+		return nil, err
+	}
+	days, err := r.repo.ListFunnel(ctx, from, to, corridor)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FunnelReport{From: from, To: to, Days: days, Corridors: []FunnelStage{}}
+	byCorridor := make(map[string]*FunnelStage)
+	for _, d := range days {
+		s, ok := byCorridor[d.Corridor]
+		if !ok {
+			s = &FunnelStage{Corridor: d.Corridor}
+			byCorridor[d.Corridor] = s
+		}
+		s.add(d)
+		report.Total.add(d)
+	}
+	for _, s := range byCorridor {
+		s.rates()
+		report.Corridors = append(report.Corridors, *s)
+	}
+	sort.Slice(report.Corridors, func(i, j int) bool {
+		return report.Corridors[i].Registered > report.Corridors[j].Registered
+	})
+	report.Total.Corridor = corridor
+	report.Total.rates()
+	if report.Days == nil {
+		report.Days = []domain.FunnelDay{}
+	}
+	return report, nil
+}
+
+// RetentionPoint is a cohort's activity in the Offset-th month after the
+// cohort month; offset 0 is the cohort itself.
+type RetentionPoint struct {
+	Offset  int       `json:"offset"`
+	Month   time.Time `json:"month"`
+	Senders int       `json:"senders"`
+	Rate    float64   `json:"rate"`
+}
+
+type Cohort struct {
+	Month     time.Time        `json:"month"`
+	Size      int              `json:"size"`
+	Retention []RetentionPoint `json:"retention"`
+}
+
+type RetentionReport struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Corridor string    `json:"corridor,omitempty"`
+	Cohorts  []Cohort  `json:"cohorts"`
+}
+
+// Retention reports the monthly retention of senders whose first completed
+// payment fell in [from, to), optionally limited to one corridor.
+func (r *Reports) Retention(ctx context.Context, from, to time.Time, corridor string) (*RetentionReport, error) {
+	if err := checkRange(from, to); err != nil {
+		return nil, err
+	}
+	from, to = startOfMonth(from), startOfMonth(to.Add(-time.Nanosecond)).AddDate(0, 1, 0)
+	cells, err := r.repo.ListRetention(ctx, from, to, corridor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sum corridors into one cell per (cohort, activity month).
+	type key struct{ cohort, activity time.Time }
+	senders := make(map[key]int)
+	var months []time.Time
+	seen := make(map[time.Time]bool)
+	for _, c := range cells {
+		cohort, activity := startOfMonth(c.CohortMonth), startOfMonth(c.ActivityMonth)
+		senders[key{cohort, activity}] += c.Senders
+		if !seen[cohort] {
+			seen[cohort] = true
+			months = append(months, cohort)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+
+	report := &RetentionReport{From: from, To: to, Corridor: corridor, Cohorts: []Cohort{}}
+	current := startOfMonth(r.now())
+	for _, m := range months {
+		c := Cohort{Month: m, Size: senders[key{m, m}]}
+		for offset, month := 0, m; !month.After(current); offset, month = offset+1, month.AddDate(0, 1, 0) {
+			n := senders[key{m, month}]
+			c.Retention = append(c.Retention, RetentionPoint{
+				Offset:  offset,
+				Month:   month,
+				Senders: n,
+				Rate:    ratio(n, c.Size),
+			})
+		}
+		report.Cohorts = append(report.Cohorts, c)
+	}
+	return report, nil
+}
+
+func checkRange(from, to time.Time) error {
+	if !from.Before(to) || to.Sub(from) > maxReportRange {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,109 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type windowCall struct{ from, to time.Time }
+
+type fakeReportRepository struct {
+	days           []domain.FunnelDay
+	cells          []domain.RetentionCell
+	funnelCalls    []windowCall
+	retentionCalls []windowCall
+}
+
+func (f *fakeReportRepository) RefreshFunnel(_ context.Context, from, to time.Time) error {
+	f.funnelCalls = append(f.funnelCalls, windowCall{from, to})
+	return nil
+}
+
+func (f *fakeReportRepository) RefreshRetention(_ context.Context, from, to time.Time) error {
+	f.retentionCalls = append(f.retentionCalls, windowCall{from, to})
+	return nil
+}
+
+func (f *fakeReportRepository) ListFunnel(context.Context, time.Time, time.Time, string) ([]domain.FunnelDay, error) {
+	return f.days, nil
+}
+
+func (f *fakeReportRepository) ListRetention(context.Context, time.Time, time.Time, string) ([]domain.RetentionCell, error) {
+	return f.cells, nil
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestRefresh_Windows(t *testing.T) {
+	repo := &fakeReportRepository{}
+	r := NewReports(repo, logger.NewNop())
+	r.now = func() time.Time { return time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, r.Refresh(context.Background()))
+	require.Len(t, repo.funnelCalls, 1)
+	assert.Equal(t, day("2023-12-16"), repo.funnelCalls[0].from)
+	assert.Equal(t, day("2024-03-16"), repo.funnelCalls[0].to)
+	require.Len(t, repo.retentionCalls, 1)
+	assert.Equal(t, day("2024-02-01"), repo.retentionCalls[0].from)
+	assert.Equal(t, day("2024-04-01"), repo.retentionCalls[0].to)
+}
+
+func TestFunnel_AggregatesCorridors(t *testing.T) {
+	repo := &fakeReportRepository{days: []domain.FunnelDay{
+		{Day: day("2024-03-01"), Corridor: "MW", Registered: 10, KYCVerified: 6, FirstPayment: 3},
+		{Day: day("2024-03-02"), Corridor: "MW", Registered: 10, KYCVerified: 4, FirstPayment: 2},
+		{Day: day("2024-03-01"), Corridor: "CN", Registered: 5, KYCVerified: 5, FirstPayment: 5},
+	}}
+	r := NewReports(repo, logger.NewNop())
+
+	report, err := r.Funnel(context.Background(), day("2024-03-01"), day("2024-03-03"), "")
+	require.NoError(t, err)
+	require.Len(t, report.Corridors, 2)
+	mw := report.Corridors[0]
+	assert.Equal(t, "MW", mw.Corridor)
+	assert.Equal(t, 20, mw.Registered)
+	assert.InDelta(t, 0.5, mw.KYCRate, 0.0001)
+	assert.InDelta(t, 0.5, mw.PaymentRate, 0.0001)
+	assert.InDelta(t, 0.25, mw.Conversion, 0.0001)
+	assert.Equal(t, 25, report.Total.Registered)
+	assert.InDelta(t, 10.0/25, report.Total.Conversion, 0.0001)
+
+	_, err = r.Funnel(context.Background(), day("2024-03-03"), day("2024-03-01"), "")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestRetention_BuildsCohorts(t *testing.T) {
+	repo := &fakeReportRepository{cells: []domain.RetentionCell{
+		{CohortMonth: day("2024-01-01"), ActivityMonth: day("2024-01-01"), Corridor: "MW", Senders: 8},
+		{CohortMonth: day("2024-01-01"), ActivityMonth: day("2024-01-01"), Corridor: "CN", Senders: 2},
+		{CohortMonth: day("2024-01-01"), ActivityMonth: day("2024-03-01"), Corridor: "MW", Senders: 4},
+		{CohortMonth: day("2024-02-01"), ActivityMonth: day("2024-02-01"), Corridor: "MW", Senders: 5},
+	}}
+	r := NewReports(repo, logger.NewNop())
+	r.now = func() time.Time { return day("2024-03-20") }
+
+	report, err := r.Retention(context.Background(), day("2024-01-01"), day("2024-03-01"), "")
+	require.NoError(t, err)
+	require.Len(t, report.Cohorts, 2)
+
+	jan := report.Cohorts[0]
+	assert.Equal(t, 10, jan.Size)
+	require.Len(t, jan.Retention, 3)
+	assert.Equal(t, 0, jan.Retention[1].Senders)
+	assert.Equal(t, 2, jan.Retention[2].Offset)
+	assert.InDelta(t, 0.4, jan.Retention[2].Rate, 0.0001)
+
+	feb := report.Cohorts[1]
+	assert.Equal(t, 5, feb.Size)
+	assert.Len(t, feb.Retention, 2)
+}
@@ -5,57 +5,142 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"time"
 
 	"kyd/internal/domain"
 	"kyd/pkg/errors"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 // APIKeyRepository defines storage operations for API keys
 type APIKeyRepository interface {
 	Create(ctx context.Context, key *domain.APIKey) error
 	List(ctx context.Context) ([]domain.APIKey, error)
+	// ListByOwner returns the keys issued to a customer account.
+	ListByOwner(ctx context.Context, owner uuid.UUID) ([]domain.APIKey, error)
 	GetByKeyHash(ctx context.Context, hash string) (*domain.APIKey, error)
 	Revoke(ctx context.Context, id uuid.UUID) error
 	UpdateLastUsed(ctx context.Context, id uuid.UUID) error
 }
 
+// ErrAPIAccessNotEntitled is returned for keys owned by an account whose
+// plan does not include API access.
+var ErrAPIAccessNotEntitled = errors.New("plan does not include API access")
+
+var (
+	ErrInvalidEnvironment = errors.New("environment must be production or sandbox")
+	// ErrEnvironmentDisabled is returned for keys of an environment their
+	// owner switched off.
+	ErrEnvironmentDisabled = errors.New("api environment is switched off for this account")
+)
+
+// EntitlementChecker reports what an account's plan allows.
+type EntitlementChecker interface {
+	Entitlements(ctx context.Context, userID uuid.UUID) (*domain.Entitlements, error)
+}
+
+// UsageMeter records billable usage.
+type UsageMeter interface {
+	RecordUsage(ctx context.Context, userID uuid.UUID, metric domain.UsageMetric, quantity decimal.Decimal, currency domain.Currency) error
+}
+
+// EnvironmentSettings reports the API environments an account switched on.
+type EnvironmentSettings interface {
+	GetEnvironments(ctx context.Context, owner uuid.UUID) (*domain.DeveloperEnvironments, error)
+}
+
+// CallLog keeps the calls made with each key for the developer portal.
+type CallLog interface {
+	RecordAPIKeyCall(ctx context.Context, call domain.APIKeyCall) error
+}
+
 type APIKeyService struct {
-	repo APIKeyRepository
+	repo         APIKeyRepository
+	entitlements EntitlementChecker
+	usage        UsageMeter
+	environments EnvironmentSettings
+	calls        CallLog
 }
 
 func NewAPIKeyService(repo APIKeyRepository) *APIKeyService {
 	return &APIKeyService{repo: repo}
 }
 
-// CreateKey generates a new API key with the given name and scopes
-func (s *APIKeyService) CreateKey(ctx context.Context, name string, scopes []string, createdBy uuid.UUID) (*domain.APIKey, string, error) {
+// WithEntitlements requires keys owned by a customer account to be on a
+// plan with API access, both when issued and on every use, so a downgrade
+// disables them.
+func (s *APIKeyService) WithEntitlements(checker EntitlementChecker) *APIKeyService {
+	s.entitlements = checker
+	return s
+}
+
+// WithUsageMeter bills every accepted call made with a customer's key to
+// that customer.
+func (s *APIKeyService) WithUsageMeter(meter UsageMeter) *APIKeyService {
+	s.usage = meter
+	return s
+}
+
+// WithEnvironments rejects keys of an environment their owner switched
+// off.
+func (s *APIKeyService) WithEnvironments(settings EnvironmentSettings) *APIKeyService {
+	s.environments = settings
+	return s
+}
+
+// WithCallLog records the calls made with each key.
+func (s *APIKeyService) WithCallLog(calls CallLog) *APIKeyService {
+	s.calls = calls
+	return s
+}
+
+// CreateKey generates a new API key with the given name and scopes in env,
+// production when empty. A key with an owner acts for that customer
+// account.
+func (s *APIKeyService) CreateKey(ctx context.Context, name string, scopes []string, createdBy uuid.UUID, owner *uuid.UUID, env domain.APIEnvironment) (*domain.APIKey, string, error) {
+	switch env {
+	case "":
+		env = domain.APIEnvironmentProduction
+	case domain.APIEnvironmentProduction, domain.APIEnvironmentSandbox:
+	default:
+		return nil, "", ErrInvalidEnvironment
+	}
+	if err := s.checkAPIAccess(ctx, owner); err != nil {
+		return nil, "", err
+	}
+
 	// Generate random key
 	keyBytes := make([]byte, 32)
 	if _, err := rand.Read(keyBytes); err != nil {
 		return nil, "", errors.Wrap(err, "failed to generate random bytes")
 	}
 
-	// Format: kyd_live_... or kyd_test_... (assuming live for now)
+	// Format: kyd_live_... for production, kyd_test_... for sandbox
 	rawKey := "kyd_live_" + hex.EncodeToString(keyBytes)
+	if env == domain.APIEnvironmentSandbox {
+		rawKey = "kyd_test_" + hex.EncodeToString(keyBytes)
+	}
 
 	// Hash the key
 	hash := sha256.Sum256([]byte(rawKey))
 	keyHash := hex.EncodeToString(hash[:])
 
-	keyPrefix := rawKey[:10] // kyd_live_ + 1 char
+	keyPrefix := rawKey[:10] // kyd_live_ or kyd_test_ + 1 char
 
 	apiKey := &domain.APIKey{
-		ID:        uuid.New(),
-		Name:      name,
-		KeyPrefix: keyPrefix,
-		KeyHash:   keyHash,
-		Scopes:    scopes,
-		IsActive:  true,
-		CreatedBy: createdBy,
-		CreatedAt: time.Now(),
+		ID:          uuid.New(),
+		Name:        name,
+		KeyPrefix:   keyPrefix,
+		KeyHash:     keyHash,
+		Scopes:      scopes,
+		IsActive:    true,
+		CreatedBy:   createdBy,
+		OwnerID:     owner,
+		Environment: env,
+		CreatedAt:   time.Now(),
 	}
 
 	if err := s.repo.Create(ctx, apiKey); err != nil {
@@ -69,6 +154,11 @@ func (s *APIKeyService) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
 	return s.repo.List(ctx)
 }
 
+// ListOwnerKeys returns the keys issued to owner, newest first.
+func (s *APIKeyService) ListOwnerKeys(ctx context.Context, owner uuid.UUID) ([]domain.APIKey, error) {
+	return s.repo.ListByOwner(ctx, owner)
+}
+
 func (s *APIKeyService) RevokeKey(ctx context.Context, id uuid.UUID) error {
 	return s.repo.Revoke(ctx, id)
 }
@@ -83,13 +173,55 @@ func (s *APIKeyService) ValidateKey(ctx context.Context, rawKey string) (*domain
 		return nil, err
 	}
 	if key == nil {
-		return nil, errors.New("invalid api key")
+		return nil, errors.ErrInvalidAPIKey
+	}
+	if err := s.checkAPIAccess(ctx, key.OwnerID); err != nil {
+		if err == ErrAPIAccessNotEntitled {
+			return nil, fmt.Errorf("%w: %v", errors.ErrAPIKeyRefused, err)
+		}
+		return nil, err
+	}
+	if s.environments != nil && key.OwnerID != nil {
+		env, err := s.environments.GetEnvironments(ctx, *key.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		if !env.Enabled(key.Environment) {
+			return nil, fmt.Errorf("%w: %v", errors.ErrAPIKeyRefused, ErrEnvironmentDisabled)
+		}
 	}
 
-	// Async update last used
+	// Async update last used and meter the call
 	go func() {
 		_ = s.repo.UpdateLastUsed(context.Background(), key.ID)
+		if s.usage != nil && key.OwnerID != nil {
+			_ = s.usage.RecordUsage(context.Background(), *key.OwnerID, domain.UsageAPICalls, decimal.NewFromInt(1), "")
+		}
 	}()
 
 	return key, nil
 }
+
+// RecordCall logs a call made with a key, in the background.
+func (s *APIKeyService) RecordCall(call domain.APIKeyCall) {
+	if s.calls == nil {
+		return
+	}
+	go func() {
+		_ = s.calls.RecordAPIKeyCall(context.Background(), call)
+	}()
+}
+
+func (s *APIKeyService) checkAPIAccess(ctx context.Context, owner *uuid.UUID) error {
+	if owner == nil || s.entitlements == nil {
+		return nil
+	}
+	ent, err := s.entitlements.Entitlements(ctx, *owner)
+	if err != nil {
+		return err
+	}
+	if !ent.APIAccess {
+		return ErrAPIAccessNotEntitled
+	}
+	return nil
+}
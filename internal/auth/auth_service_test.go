@@ -57,11 +57,21 @@ func (m *MockRepository) SetEmailVerified(ctx context.Context, id uuid.UUID) err
 	return args.Error(0)
 }
 
+func (m *MockRepository) SetPhoneVerified(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func (m *MockRepository) AddDevice(ctx context.Context, device *domain.UserDevice) error {
 	args := m.Called(ctx, device)
 	return args.Error(0)
 }
 
+func (m *MockRepository) HasDevice(ctx context.Context, userID uuid.UUID, deviceHash string) (bool, error) {
+	args := m.Called(ctx, userID, deviceHash)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockRepository) IsCountryTrusted(ctx context.Context, userID uuid.UUID, countryCode string) (bool, error) {
 	args := m.Called(ctx, userID, countryCode)
 	return args.Bool(0), args.Error(1)
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDeviceNotFound       = errors.New("device not found")
+	ErrDevicesNotConfigured = errors.New("device management is not configured")
+	ErrInvalidDeviceName    = errors.New("device name must be at most 100 characters")
+)
+
+const maxDeviceNameLength = 100
+
+// DeviceRepository manages the devices users have signed in from. The
+// methods taking a device ID only touch the user's own devices and report
+// false, or nil, when they have no such device.
+type DeviceRepository interface {
+	ListDevices(ctx context.Context, userID uuid.UUID) ([]domain.UserDevice, error)
+	GetDevice(ctx context.Context, userID, id uuid.UUID) (*domain.UserDevice, error)
+	LabelDevice(ctx context.Context, userID, id uuid.UUID, label *string) (bool, error)
+	SetDeviceTrusted(ctx context.Context, userID, id uuid.UUID, trusted bool) (bool, error)
+	DeleteDevice(ctx context.Context, userID, id uuid.UUID) (bool, error)
+}
+
+// WithDevices lets users manage their devices. Devices they sign in to then
+// start untrusted unless a second factor was proven at login, and must be
+// trusted with TrustDevice before they can move money.
+func (s *Service) WithDevices(repo DeviceRepository) *Service {
+	s.devices = repo
+	return s
+}
+
+// trustOnLogin decides whether a device first seen at this login is
+// trusted. The first login after registration is, since there is nothing
+// to confirm it with yet, as is every login of a user without a second
+// factor to confirm a device with.
+func (s *Service) trustOnLogin(ctx context.Context, user *domain.User, returning, secondFactor bool) bool {
+	if s.devices == nil || secondFactor || !returning {
+		return true
+	}
+	methods, err := s.mfaMethods(ctx, user)
+	if err != nil {
+		return false
+	}
+	return len(methods) == 0
+}
+
+// Devices lists the devices the user has signed in from.
+func (s *Service) Devices(ctx context.Context, userID uuid.UUID) ([]domain.UserDevice, error) {
+	if s.devices == nil {
+		return nil, ErrDevicesNotConfigured
+	}
+	return s.devices.ListDevices(ctx, userID)
+}
+
+// RenameDevice names one of the user's devices; an empty name clears it.
+func (s *Service) RenameDevice(ctx context.Context, userID, deviceID uuid.UUID, name string) (*domain.UserDevice, error) {
+	if s.devices == nil {
+		return nil, ErrDevicesNotConfigured
+	}
+	name = strings.TrimSpace(name)
+	if len([]rune(name)) > maxDeviceNameLength {
+		return nil, ErrInvalidDeviceName
+	}
+	var label *string
+	if name != "" {
+		label = &name
+	}
+	ok, err := s.devices.LabelDevice(ctx, userID, deviceID, label)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDeviceNotFound
+	}
+	return s.device(ctx, userID, deviceID)
+}
+
+// SendDeviceTrustCode sends a code by SMS or email with which the user
+// confirms trusting one of their devices in TrustDevice.
+func (s *Service) SendDeviceTrustCode(ctx context.Context, userID, deviceID uuid.UUID, method domain.MFAMethod) error {
+	if s.devices == nil {
+		return ErrDevicesNotConfigured
+	}
+	if _, err := s.device(ctx, userID, deviceID); err != nil {
+		return err
+	}
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.sendMFACode(ctx, user, method, "device confirmation",
+		"If you did not ask to trust a device, remove it from your devices and change your password.")
+}
+
+// TrustDevice trusts one of the user's devices once they prove a second
+// factor: a code sent by SendDeviceTrustCode, a TOTP code or a backup code.
+// Wrong codes count towards the step-up lockout.
+func (s *Service) TrustDevice(ctx context.Context, userID, deviceID uuid.UUID, method domain.MFAMethod, code string) (*domain.UserDevice, error) {
+	if s.devices == nil {
+		return nil, ErrDevicesNotConfigured
+	}
+	if _, err := s.device(ctx, userID, deviceID); err != nil {
+		return nil, err
+	}
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, user, method, code); err != nil {
+		return nil, err
+	}
+	return s.setDeviceTrusted(ctx, userID, deviceID, true)
+}
+
+// UntrustDevice stops one of the user's devices from moving money until it
+// is trusted again. Its sessions stay signed in.
+func (s *Service) UntrustDevice(ctx context.Context, userID, deviceID uuid.UUID) (*domain.UserDevice, error) {
+	if s.devices == nil {
+		return nil, ErrDevicesNotConfigured
+	}
+	return s.setDeviceTrusted(ctx, userID, deviceID, false)
+}
+
+// RevokeDevice forgets one of the user's devices and signs out every session
+// on it. Signing in from it again is treated as a new device.
+func (s *Service) RevokeDevice(ctx context.Context, userID, deviceID uuid.UUID) error {
+	if s.devices == nil {
+		return ErrDevicesNotConfigured
+	}
+	ok, err := s.devices.DeleteDevice(ctx, userID, deviceID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	if s.blacklist == nil {
+		return nil
+	}
+	// Tokens issued on the device expire within jwtExpiry
+	return s.blacklist.Blacklist(ctx, domain.DeviceRevocationKey(deviceID), s.jwtExpiry)
+}
+
+func (s *Service) setDeviceTrusted(ctx context.Context, userID, deviceID uuid.UUID, trusted bool) (*domain.UserDevice, error) {
+	ok, err := s.devices.SetDeviceTrusted(ctx, userID, deviceID, trusted)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDeviceNotFound
+	}
+	return s.device(ctx, userID, deviceID)
+}
+
+func (s *Service) device(ctx context.Context, userID, deviceID uuid.UUID) (*domain.UserDevice, error) {
+	d, err := s.devices.GetDevice(ctx, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDeviceNotFound
+	}
+	return d, nil
+}
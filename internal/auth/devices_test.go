@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryDevices stands in for the user repository's device table.
+type memoryDevices struct {
+	byID map[uuid.UUID]*domain.UserDevice
+}
+
+func (d *memoryDevices) add(device *domain.UserDevice) {
+	for _, have := range d.byID {
+		if have.UserID == device.UserID && have.DeviceHash == device.DeviceHash {
+			have.IsTrusted = have.IsTrusted || device.IsTrusted
+			device.ID, device.IsTrusted = have.ID, have.IsTrusted
+			return
+		}
+	}
+	device.ID = uuid.New()
+	cp := *device
+	d.byID[device.ID] = &cp
+}
+
+func (d *memoryDevices) ListDevices(_ context.Context, userID uuid.UUID) ([]domain.UserDevice, error) {
+	var out []domain.UserDevice
+	for _, device := range d.byID {
+		if device.UserID == userID {
+			out = append(out, *device)
+		}
+	}
+	return out, nil
+}
+
+func (d *memoryDevices) GetDevice(_ context.Context, userID, id uuid.UUID) (*domain.UserDevice, error) {
+	device, ok := d.byID[id]
+	if !ok || device.UserID != userID {
+		return nil, nil
+	}
+	cp := *device
+	return &cp, nil
+}
+
+func (d *memoryDevices) LabelDevice(ctx context.Context, userID, id uuid.UUID, label *string) (bool, error) {
+	device, _ := d.GetDevice(ctx, userID, id)
+	if device == nil {
+		return false, nil
+	}
+	d.byID[id].Label = label
+	return true, nil
+}
+
+func (d *memoryDevices) SetDeviceTrusted(ctx context.Context, userID, id uuid.UUID, trusted bool) (bool, error) {
+	device, _ := d.GetDevice(ctx, userID, id)
+	if device == nil {
+		return false, nil
+	}
+	d.byID[id].IsTrusted = trusted
+	return true, nil
+}
+
+func (d *memoryDevices) DeleteDevice(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	device, _ := d.GetDevice(ctx, userID, id)
+	if device == nil {
+		return false, nil
+	}
+	delete(d.byID, id)
+	return true, nil
+}
+
+func newDeviceFixture(t *testing.T) (*mfaFixture, *memoryDevices) {
+	f := newMFAFixture(t, MFASettings{})
+	devices := &memoryDevices{byID: make(map[uuid.UUID]*domain.UserDevice)}
+	f.service.WithDevices(devices)
+	lastLogin := time.Now().Add(-24 * time.Hour)
+	f.user.LastLogin = &lastLogin
+	f.user.LoginAlertsDisabled = true
+	f.repo.On("AddDevice", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		devices.add(args.Get(1).(*domain.UserDevice))
+	}).Return(nil)
+	return f, devices
+}
+
+func (f *mfaFixture) loginFrom(t *testing.T, deviceID string) *TokenResponse {
+	resp, err := f.service.Login(context.Background(), &LoginRequest{Email: f.user.Email, Password: "Correct123!", DeviceID: deviceID})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Device)
+	return resp
+}
+
+func TestNewDeviceIsTrustedAfterOTP(t *testing.T) {
+	ctx := context.Background()
+	f, _ := newDeviceFixture(t)
+
+	device := f.loginFrom(t, "laptop").Device
+	assert.False(t, device.IsTrusted, "a returning user's new device needs confirming")
+
+	assert.ErrorIs(t, f.service.SendDeviceTrustCode(ctx, f.user.ID, device.ID, domain.MFAMethodEmail), ErrMFAMethodUnavailable)
+	require.NoError(t, f.service.SendDeviceTrustCode(ctx, f.user.ID, device.ID, domain.MFAMethodSMS))
+	code := f.sms.lastCode()
+
+	_, err := f.service.TrustDevice(ctx, f.user.ID, device.ID, domain.MFAMethodSMS, "000000")
+	assert.ErrorIs(t, err, kyderrors.ErrInvalidTOTP)
+	_, err = f.service.TrustDevice(ctx, uuid.New(), device.ID, domain.MFAMethodSMS, code)
+	assert.ErrorIs(t, err, ErrDeviceNotFound, "only the owner may trust it")
+	trusted, err := f.service.TrustDevice(ctx, f.user.ID, device.ID, domain.MFAMethodSMS, code)
+	require.NoError(t, err)
+	assert.True(t, trusted.IsTrusted)
+
+	// Signing in again does not undo the trust
+	assert.True(t, f.loginFrom(t, "laptop").Device.IsTrusted)
+
+	renamed, err := f.service.RenameDevice(ctx, f.user.ID, device.ID, "  Work laptop ")
+	require.NoError(t, err)
+	require.NotNil(t, renamed.Label)
+	assert.Equal(t, "Work laptop", *renamed.Label)
+
+	untrusted, err := f.service.UntrustDevice(ctx, f.user.ID, device.ID)
+	require.NoError(t, err)
+	assert.False(t, untrusted.IsTrusted)
+}
+
+func TestDeviceTrustOnLogin(t *testing.T) {
+	f, _ := newDeviceFixture(t)
+
+	// The device proven with a second factor at login
+	secret := f.enrollTOTP(t)
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	resp, err := f.service.Login(context.Background(), &LoginRequest{Email: f.user.Email, Password: "Correct123!", TOTPCode: code, DeviceID: "phone"})
+	require.NoError(t, err)
+	assert.True(t, resp.Device.IsTrusted)
+
+	// Nothing to confirm a device with
+	f.user.IsTOTPEnabled, f.user.TOTPSecret, f.user.PhoneVerified = false, nil, false
+	assert.True(t, f.loginFrom(t, "tablet").Device.IsTrusted)
+
+	// The first login after registration
+	f.user.PhoneVerified = true
+	f.user.LastLogin = nil
+	assert.True(t, f.loginFrom(t, "desktop").Device.IsTrusted)
+}
+
+func TestRevokeDeviceSignsItOut(t *testing.T) {
+	ctx := context.Background()
+	f, devices := newDeviceFixture(t)
+	blacklist := &stubBlacklist{revoked: map[string]bool{}}
+	f.service.blacklist = blacklist
+
+	resp := f.loginFrom(t, "laptop")
+	other := f.loginFrom(t, "phone")
+	info, err := f.service.IntrospectToken(ctx, resp.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, info.Active)
+
+	assert.ErrorIs(t, f.service.RevokeDevice(ctx, uuid.New(), resp.Device.ID), ErrDeviceNotFound)
+	require.NoError(t, f.service.RevokeDevice(ctx, f.user.ID, resp.Device.ID))
+	assert.NotContains(t, devices.byID, resp.Device.ID)
+
+	info, err = f.service.IntrospectToken(ctx, resp.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, info.Active)
+	info, err = f.service.IntrospectToken(ctx, other.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, info.Active, "other devices stay signed in")
+
+	assert.ErrorIs(t, f.service.RevokeDevice(ctx, f.user.ID, resp.Device.ID), ErrDeviceNotFound)
+}
@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/mailer"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRejectionNotFound = errors.New("eligibility rejection not found")
+	ErrAppealNotAllowed  = errors.New("rejection cannot be appealed")
+	ErrAppealNotPending  = errors.New("appeal is not awaiting review")
+	ErrInvalidRule       = errors.New("invalid eligibility rule")
+)
+
+// IneligibleError is returned by Register when the applicant fails the
+// eligibility rules of their country. RejectionID identifies the record an
+// appeal refers to.
+type IneligibleError struct {
+	RejectionID uuid.UUID
+	Reasons     domain.EligibilityReasons
+}
+
+func (e *IneligibleError) Error() string {
+	codes := make([]string, len(e.Reasons))
+	for i, r := range e.Reasons {
+		codes[i] = r.Code
+	}
+	return "not eligible to register: " + strings.Join(codes, ", ")
+}
+
+// EligibilityRepository stores per-country rules and rejected registrations.
+type EligibilityRepository interface {
+	// GetEligibilityRule returns nil when the country has no rule.
+	GetEligibilityRule(ctx context.Context, countryCode string) (*domain.EligibilityRule, error)
+	ListEligibilityRules(ctx context.Context) ([]domain.EligibilityRule, error)
+	UpsertEligibilityRule(ctx context.Context, rule *domain.EligibilityRule) error
+	DeleteEligibilityRule(ctx context.Context, countryCode string) error
+
+	CreateEligibilityRejection(ctx context.Context, r *domain.EligibilityRejection) error
+	// GetEligibilityRejection returns nil when there is no such rejection.
+	GetEligibilityRejection(ctx context.Context, id uuid.UUID) (*domain.EligibilityRejection, error)
+	ListEligibilityRejections(ctx context.Context, status domain.EligibilityRejectionStatus, limit, offset int) ([]domain.EligibilityRejection, int, error)
+	UpdateEligibilityRejection(ctx context.Context, r *domain.EligibilityRejection) error
+	// HasApprovedAppeal reports whether an appeal by email for countryCode
+	// was approved.
+	HasApprovedAppeal(ctx context.Context, email, countryCode string) (bool, error)
+}
+
+// AppealHook is told about appeals as they move through review, e.g. to
+// open a compliance case or notify reviewers.
+type AppealHook interface {
+	AppealSubmitted(ctx context.Context, r *domain.EligibilityRejection)
+	AppealDecided(ctx context.Context, r *domain.EligibilityRejection)
+}
+
+// EligibilityPolicy is the rule for countries without one of their own.
+type EligibilityPolicy struct {
+	Enabled bool
+	Default domain.EligibilityRule
+}
+
+// EligibilityPolicyFromConfig builds the default eligibility rule.
+func EligibilityPolicyFromConfig(cfg config.EligibilityConfig) EligibilityPolicy {
+	return EligibilityPolicy{
+		Enabled: cfg.Enabled,
+		Default: domain.EligibilityRule{
+			MinAge:                  cfg.DefaultMinAge,
+			RestrictedNationalities: normalizeCodes(cfg.RestrictedNationalities, strings.ToUpper),
+		},
+	}
+}
+
+// WithEligibility checks applicants against their country's rules before
+// an account is created. The admin and appeal methods need it.
+func (s *Service) WithEligibility(p EligibilityPolicy, repo EligibilityRepository, hooks ...AppealHook) *Service {
+	s.eligibility = p
+	s.eligibilityRules = repo
+	s.appealHooks = hooks
+	return s
+}
+
+// eligibilityRule returns the rule that applies in countryCode.
+func (s *Service) eligibilityRule(ctx context.Context, countryCode string) (*domain.EligibilityRule, error) {
+	rule, err := s.eligibilityRules.GetEligibilityRule(ctx, countryCode)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		def := s.eligibility.Default
+		def.CountryCode = countryCode
+		rule = &def
+	}
+	return rule, nil
+}
+
+// checkEligibility records and returns an *IneligibleError when req fails
+// the rules, unless an appeal by the same applicant was approved.
+func (s *Service) checkEligibility(ctx context.Context, req *RegisterRequest, dob *time.Time) error {
+	if !s.eligibility.Enabled || s.eligibilityRules == nil {
+		return nil
+	}
+	country := strings.ToUpper(req.CountryCode)
+	rule, err := s.eligibilityRule(ctx, country)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	reasons := rule.Check(domain.EligibilityApplicant{
+		CountryCode:  country,
+		UserType:     req.UserType,
+		DateOfBirth:  dob,
+		Nationality:  req.Nationality,
+		BusinessType: req.BusinessType,
+	}, now)
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	approved, err := s.eligibilityRules.HasApprovedAppeal(ctx, req.Email, country)
+	if err != nil {
+		return err
+	}
+	if approved {
+		return nil
+	}
+
+	rejection := &domain.EligibilityRejection{
+		ID:           uuid.New(),
+		Email:        req.Email,
+		CountryCode:  country,
+		UserType:     req.UserType,
+		DateOfBirth:  dob,
+		Nationality:  strings.ToUpper(req.Nationality),
+		BusinessType: strings.ToLower(req.BusinessType),
+		Reasons:      reasons,
+		Status:       domain.EligibilityRejected,
+		CreatedAt:    now,
+	}
+	if err := s.eligibilityRules.CreateEligibilityRejection(ctx, rejection); err != nil {
+		return err
+	}
+	return &IneligibleError{RejectionID: rejection.ID, Reasons: reasons}
+}
+
+// ListEligibilityRules returns the per-country rules and the default rule.
+func (s *Service) ListEligibilityRules(ctx context.Context) ([]domain.EligibilityRule, domain.EligibilityRule, error) {
+	rules, err := s.eligibilityRules.ListEligibilityRules(ctx)
+	if err != nil {
+		return nil, domain.EligibilityRule{}, err
+	}
+	return rules, s.eligibility.Default, nil
+}
+
+// SetEligibilityRule creates or replaces a country's rule.
+func (s *Service) SetEligibilityRule(ctx context.Context, rule *domain.EligibilityRule, actor uuid.UUID) error {
+	rule.CountryCode = strings.ToUpper(rule.CountryCode)
+	if len(rule.CountryCode) != 2 {
+		return fmt.Errorf("%w: country code must have 2 letters", ErrInvalidRule)
+	}
+	if rule.MinAge < 0 || rule.MinAge > 120 {
+		return fmt.Errorf("%w: min_age must be between 0 and 120", ErrInvalidRule)
+	}
+	rule.RestrictedNationalities = normalizeCodes(rule.RestrictedNationalities, strings.ToUpper)
+	rule.RestrictedUserTypes = normalizeCodes(rule.RestrictedUserTypes, strings.ToLower)
+	for _, t := range rule.RestrictedUserTypes {
+		switch domain.UserType(t) {
+		case domain.UserTypeIndividual, domain.UserTypeMerchant, domain.UserTypeAgent:
+		default:
+			return fmt.Errorf("%w: unknown user type %q", ErrInvalidRule, t)
+		}
+	}
+	rule.RestrictedBusinessTypes = normalizeCodes(rule.RestrictedBusinessTypes, strings.ToLower)
+	rule.UpdatedBy = &actor
+	rule.UpdatedAt = time.Now()
+	return s.eligibilityRules.UpsertEligibilityRule(ctx, rule)
+}
+
+// DeleteEligibilityRule returns a country to the default rule.
+func (s *Service) DeleteEligibilityRule(ctx context.Context, countryCode string) error {
+	return s.eligibilityRules.DeleteEligibilityRule(ctx, strings.ToUpper(countryCode))
+}
+
+// AppealRejection asks for a rejected registration to be reviewed. The
+// email must be the one the registration was made with.
+func (s *Service) AppealRejection(ctx context.Context, id uuid.UUID, email, statement string) (*domain.EligibilityRejection, error) {
+	r, err := s.findRejection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(r.Email, strings.TrimSpace(email)) {
+		return nil, ErrRejectionNotFound
+	}
+	if r.Status != domain.EligibilityRejected {
+		return nil, ErrAppealNotAllowed
+	}
+	now := time.Now()
+	r.Status = domain.EligibilityAppealed
+	r.AppealStatement = &statement
+	r.AppealedAt = &now
+	if err := s.eligibilityRules.UpdateEligibilityRejection(ctx, r); err != nil {
+		return nil, err
+	}
+	for _, h := range s.appealHooks {
+		h.AppealSubmitted(ctx, r)
+	}
+	return r, nil
+}
+
+// ListEligibilityRejections lists rejected registrations, optionally only
+// those in status.
+func (s *Service) ListEligibilityRejections(ctx context.Context, status domain.EligibilityRejectionStatus, limit, offset int) ([]domain.EligibilityRejection, int, error) {
+	return s.eligibilityRules.ListEligibilityRejections(ctx, status, limit, offset)
+}
+
+// DecideAppeal approves or denies a pending appeal. Once approved the
+// applicant can register in that country despite the rules, and is emailed
+// either way.
+func (s *Service) DecideAppeal(ctx context.Context, id uuid.UUID, approve bool, note string, reviewer uuid.UUID) (*domain.EligibilityRejection, error) {
+	r, err := s.findRejection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != domain.EligibilityAppealed {
+		return nil, ErrAppealNotPending
+	}
+	now := time.Now()
+	r.Status = domain.EligibilityDenied
+	if approve {
+		r.Status = domain.EligibilityApproved
+	}
+	r.ReviewedBy = &reviewer
+	r.ReviewedAt = &now
+	if note != "" {
+		r.ReviewNote = &note
+	}
+	if err := s.eligibilityRules.UpdateEligibilityRejection(ctx, r); err != nil {
+		return nil, err
+	}
+	s.sendAppealDecision(r)
+	for _, h := range s.appealHooks {
+		h.AppealDecided(ctx, r)
+	}
+	return r, nil
+}
+
+func (s *Service) findRejection(ctx context.Context, id uuid.UUID) (*domain.EligibilityRejection, error) {
+	r, err := s.eligibilityRules.GetEligibilityRejection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, ErrRejectionNotFound
+	}
+	return r, nil
+}
+
+// sendAppealDecision emails the applicant the outcome of their appeal in
+// the background.
+func (s *Service) sendAppealDecision(r *domain.EligibilityRejection) {
+	if s.mailer == nil {
+		return
+	}
+	outcome := "has been declined. We are unable to open an account for you at this time."
+	if r.Status == domain.EligibilityApproved {
+		outcome = "has been approved. You can now complete your registration."
+	}
+	body := fmt.Sprintf("<p>Hello,</p>\n<p>Your appeal against the decision on your KYD registration (reference %s) %s</p>", r.ID, outcome)
+	if r.ReviewNote != nil {
+		body += fmt.Sprintf("\n<p>Reviewer's note: %s</p>", html.EscapeString(*r.ReviewNote))
+	}
+	to := r.Email
+	go func() {
+		_ = s.mailer.SendKind(context.Background(), mailer.KindAppeal, to, "Your KYD registration appeal", body)
+	}()
+}
+
+// normalizeCodes trims, cases and de-duplicates codes, dropping blanks.
+func normalizeCodes(codes []string, norm func(string) string) []string {
+	out := make([]string, 0, len(codes))
+	seen := make(map[string]struct{}, len(codes))
+	for _, c := range codes {
+		c = norm(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryEligibility struct {
+	rules      map[string]domain.EligibilityRule
+	rejections map[uuid.UUID]*domain.EligibilityRejection
+}
+
+func newMemoryEligibility() *memoryEligibility {
+	return &memoryEligibility{rules: map[string]domain.EligibilityRule{}, rejections: map[uuid.UUID]*domain.EligibilityRejection{}}
+}
+
+func (m *memoryEligibility) GetEligibilityRule(ctx context.Context, countryCode string) (*domain.EligibilityRule, error) {
+	rule, ok := m.rules[countryCode]
+	if !ok {
+		return nil, nil
+	}
+	return &rule, nil
+}
+
+func (m *memoryEligibility) ListEligibilityRules(ctx context.Context) ([]domain.EligibilityRule, error) {
+	var rules []domain.EligibilityRule
+	for _, r := range m.rules {
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (m *memoryEligibility) UpsertEligibilityRule(ctx context.Context, rule *domain.EligibilityRule) error {
+	m.rules[rule.CountryCode] = *rule
+	return nil
+}
+
+func (m *memoryEligibility) DeleteEligibilityRule(ctx context.Context, countryCode string) error {
+	delete(m.rules, countryCode)
+	return nil
+}
+
+func (m *memoryEligibility) CreateEligibilityRejection(ctx context.Context, r *domain.EligibilityRejection) error {
+	cp := *r
+	m.rejections[r.ID] = &cp
+	return nil
+}
+
+func (m *memoryEligibility) GetEligibilityRejection(ctx context.Context, id uuid.UUID) (*domain.EligibilityRejection, error) {
+	r, ok := m.rejections[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *r
+	return &cp, nil
+}
+
+func (m *memoryEligibility) ListEligibilityRejections(ctx context.Context, status domain.EligibilityRejectionStatus, limit, offset int) ([]domain.EligibilityRejection, int, error) {
+	var out []domain.EligibilityRejection
+	for _, r := range m.rejections {
+		if status == "" || r.Status == status {
+			out = append(out, *r)
+		}
+	}
+	return out, len(out), nil
+}
+
+func (m *memoryEligibility) UpdateEligibilityRejection(ctx context.Context, r *domain.EligibilityRejection) error {
+	cp := *r
+	m.rejections[r.ID] = &cp
+	return nil
+}
+
+func (m *memoryEligibility) HasApprovedAppeal(ctx context.Context, email, countryCode string) (bool, error) {
+	for _, r := range m.rejections {
+		if strings.EqualFold(r.Email, email) && r.CountryCode == countryCode && r.Status == domain.EligibilityApproved {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type recordingHook struct{ submitted, decided []uuid.UUID }
+
+func (h *recordingHook) AppealSubmitted(ctx context.Context, r *domain.EligibilityRejection) {
+	h.submitted = append(h.submitted, r.ID)
+}
+
+func (h *recordingHook) AppealDecided(ctx context.Context, r *domain.EligibilityRejection) {
+	h.decided = append(h.decided, r.ID)
+}
+
+func codesOf(reasons domain.EligibilityReasons) []string {
+	codes := make([]string, len(reasons))
+	for i, r := range reasons {
+		codes[i] = r.Code
+	}
+	return codes
+}
+
+func TestEligibilityRuleCheck(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	date := func(s string) *time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return &d
+	}
+	rule := domain.EligibilityRule{
+		MinAge:                  18,
+		RestrictedNationalities: []string{"KP"},
+		RestrictedUserTypes:     []string{"agent"},
+		RestrictedBusinessTypes: []string{"gambling"},
+	}
+
+	tests := []struct {
+		name      string
+		applicant domain.EligibilityApplicant
+		want      []string
+	}{
+		{"eligible", domain.EligibilityApplicant{UserType: domain.UserTypeIndividual, DateOfBirth: date("2008-10-16"), Nationality: "mw"}, []string{}},
+		{"day before 18th birthday", domain.EligibilityApplicant{UserType: domain.UserTypeIndividual, DateOfBirth: date("2008-10-17"), Nationality: "MW"}, []string{domain.EligibilityUnderage}},
+		{"missing declarations", domain.EligibilityApplicant{UserType: domain.UserTypeIndividual}, []string{domain.EligibilityDateOfBirthRequired, domain.EligibilityNationalityRequired}},
+		{"future birth date", domain.EligibilityApplicant{UserType: domain.UserTypeIndividual, DateOfBirth: date("2030-01-01"), Nationality: "MW"}, []string{domain.EligibilityDateOfBirthInvalid}},
+		{"restricted nationality", domain.EligibilityApplicant{UserType: domain.UserTypeIndividual, DateOfBirth: date("1990-01-01"), Nationality: "kp"}, []string{domain.EligibilityNationalityRestricted}},
+		{"restricted user type", domain.EligibilityApplicant{UserType: domain.UserTypeAgent, DateOfBirth: date("1990-01-01"), Nationality: "MW", BusinessType: "retail"}, []string{domain.EligibilityUserTypeRestricted}},
+		{"restricted business", domain.EligibilityApplicant{UserType: domain.UserTypeMerchant, DateOfBirth: date("1990-01-01"), Nationality: "MW", BusinessType: "Gambling"}, []string{domain.EligibilityBusinessTypeRestricted}},
+		{"business type missing", domain.EligibilityApplicant{UserType: domain.UserTypeMerchant, DateOfBirth: date("1990-01-01"), Nationality: "MW"}, []string{domain.EligibilityBusinessTypeRequired}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, codesOf(rule.Check(tt.applicant, now)))
+		})
+	}
+}
+
+func TestRegisterEligibility(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	store := newMemoryEligibility()
+	hook := &recordingHook{}
+	service := NewService(repo, nil, "secret", time.Hour).
+		WithEligibility(EligibilityPolicy{Enabled: true, Default: domain.EligibilityRule{MinAge: 18}}, store, hook)
+	admin := uuid.New()
+
+	require.NoError(t, service.SetEligibilityRule(ctx, &domain.EligibilityRule{CountryCode: "za", MinAge: 21, RestrictedNationalities: []string{" kp "}}, admin))
+	assert.Equal(t, []string{"KP"}, []string(store.rules["ZA"].RestrictedNationalities))
+	assert.ErrorIs(t, service.SetEligibilityRule(ctx, &domain.EligibilityRule{CountryCode: "ZA", RestrictedUserTypes: []string{"robot"}}, admin), ErrInvalidRule)
+
+	register := func(email, country, dob string) (*TokenResponse, error) {
+		return service.Register(ctx, &RegisterRequest{
+			Email:       email,
+			Phone:       "+265888123456",
+			Password:    "Password123!",
+			FirstName:   "Test",
+			LastName:    "User",
+			UserType:    domain.UserTypeIndividual,
+			CountryCode: country,
+			DateOfBirth: dob,
+			Nationality: "MW",
+		})
+	}
+	twentyYearsAgo := time.Now().AddDate(-20, 0, 0).Format("2006-01-02")
+
+	t.Run("default rule applies without a country rule", func(t *testing.T) {
+		repo.On("ExistsByEmail", mock.Anything, "adult@example.com").Return(false, nil).Once()
+		repo.On("Create", mock.Anything, mock.MatchedBy(func(u *domain.User) bool {
+			return u.DateOfBirth != nil && u.DateOfBirth.Format("2006-01-02") == twentyYearsAgo
+		})).Return(nil).Once()
+		_, err := register("adult@example.com", "MW", twentyYearsAgo)
+		require.NoError(t, err)
+	})
+
+	var rejectionID uuid.UUID
+	t.Run("country rule rejects and records the applicant", func(t *testing.T) {
+		repo.On("ExistsByEmail", mock.Anything, "young@example.com").Return(false, nil)
+		_, err := register("young@example.com", "ZA", twentyYearsAgo)
+		var ineligible *IneligibleError
+		require.True(t, errors.As(err, &ineligible))
+		assert.Equal(t, []string{domain.EligibilityUnderage}, codesOf(ineligible.Reasons))
+		rejectionID = ineligible.RejectionID
+
+		rec := store.rejections[rejectionID]
+		require.NotNil(t, rec)
+		assert.Equal(t, "ZA", rec.CountryCode)
+		assert.Equal(t, domain.EligibilityRejected, rec.Status)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.MatchedBy(func(u *domain.User) bool { return u.Email == "young@example.com" }))
+	})
+
+	t.Run("appeal", func(t *testing.T) {
+		_, err := service.AppealRejection(ctx, rejectionID, "someone@example.com", "I am old enough")
+		assert.ErrorIs(t, err, ErrRejectionNotFound)
+		_, err = service.DecideAppeal(ctx, rejectionID, true, "", admin)
+		assert.ErrorIs(t, err, ErrAppealNotPending)
+
+		rec, err := service.AppealRejection(ctx, rejectionID, "YOUNG@example.com", "My passport shows I am 21")
+		require.NoError(t, err)
+		assert.Equal(t, domain.EligibilityAppealed, rec.Status)
+		_, err = service.AppealRejection(ctx, rejectionID, "young@example.com", "again")
+		assert.ErrorIs(t, err, ErrAppealNotAllowed)
+
+		rec, err = service.DecideAppeal(ctx, rejectionID, true, "Passport checked", admin)
+		require.NoError(t, err)
+		assert.Equal(t, domain.EligibilityApproved, rec.Status)
+		assert.Equal(t, &admin, rec.ReviewedBy)
+		assert.Equal(t, []uuid.UUID{rejectionID}, hook.submitted)
+		assert.Equal(t, []uuid.UUID{rejectionID}, hook.decided)
+	})
+
+	t.Run("approved appeal lets the applicant register", func(t *testing.T) {
+		repo.On("Create", mock.Anything, mock.MatchedBy(func(u *domain.User) bool { return u.Email == "young@example.com" })).Return(nil).Once()
+		_, err := register("young@example.com", "ZA", twentyYearsAgo)
+		require.NoError(t, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		off := NewService(repo, nil, "secret", time.Hour).WithEligibility(EligibilityPolicy{Default: domain.EligibilityRule{MinAge: 18}}, store)
+		repo.On("ExistsByEmail", mock.Anything, "nodob@example.com").Return(false, nil).Once()
+		repo.On("Create", mock.Anything, mock.MatchedBy(func(u *domain.User) bool { return u.Email == "nodob@example.com" })).Return(nil).Once()
+		_, err := off.Register(ctx, &RegisterRequest{Email: "nodob@example.com", Password: "Password123!", UserType: domain.UserTypeIndividual, CountryCode: "MW"})
+		require.NoError(t, err)
+	})
+}
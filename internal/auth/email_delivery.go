@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"kyd/pkg/mailer"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrEmailNotFailed is returned when asked to resend a message that was
+	// delivered or is still being retried.
+	ErrEmailNotFailed = errors.New("email has not failed")
+	// ErrEmailAlreadyVerified is returned when asked to resend a verification
+	// link to an address that has since been verified.
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+// ListEmails lists tracked outgoing email for admins.
+func (s *Service) ListEmails(ctx context.Context, f mailer.MessageFilter) ([]*mailer.Message, int, error) {
+	if s.mailer == nil {
+		return []*mailer.Message{}, 0, nil
+	}
+	return s.mailer.Messages(ctx, f)
+}
+
+// ResendEmail sends a failed, bounced or suppressed message again. The
+// links in verification, password reset and invitation emails expire, so
+// those are regenerated rather than copied. With liftSuppression the recipient is
+// first taken off the suppression list, for when they have fixed their
+// mailbox.
+func (s *Service) ResendEmail(ctx context.Context, id uuid.UUID, liftSuppression bool) error {
+	if s.mailer == nil {
+		return errors.New("email is not configured")
+	}
+	msg, err := s.mailer.Message(ctx, id)
+	if err != nil {
+		return err
+	}
+	switch msg.Status {
+	case mailer.StatusFailed, mailer.StatusBounced, mailer.StatusSuppressed:
+	default:
+		return ErrEmailNotFailed
+	}
+	if liftSuppression {
+		if err := s.mailer.Unsuppress(ctx, msg.To); err != nil {
+			return err
+		}
+	}
+
+	ctx = mailer.WithResentFrom(ctx, msg.ID)
+	switch msg.Kind {
+	case mailer.KindVerification:
+		user, err := s.repo.FindByEmail(ctx, msg.To)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerified {
+			return ErrEmailAlreadyVerified
+		}
+		return s.sendVerificationEmail(ctx, user)
+	case mailer.KindPasswordReset:
+		return s.RequestPasswordReset(ctx, msg.To)
+	case mailer.KindInvitation:
+		user, err := s.repo.FindByEmail(ctx, msg.To)
+		if err != nil {
+			return err
+		}
+		if user.IsActive || user.PasswordHash != "" {
+			return ErrInvitationAccepted
+		}
+		return s.SendInvitation(ctx, user)
+	default:
+		_, err := s.mailer.Resend(ctx, id, false)
+		return err
+	}
+}
@@ -14,7 +14,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
-	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/idtoken"
@@ -238,11 +237,11 @@ func (s *GoogleOAuthService) HandleGoogleSignIn(ctx context.Context, userInfo *G
 
 	// Hash the random password
 	if randomPassword != "" {
-		passwordHash, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+		passwordHash, err := s.authService.passwordPolicy.hashPassword(randomPassword)
 		if err != nil {
 			return nil, fmt.Errorf("failed to hash password: %w", err)
 		}
-		user.PasswordHash = string(passwordHash)
+		user.PasswordHash = passwordHash
 	}
 
 	if err := s.authService.repo.Create(ctx, user); err != nil {
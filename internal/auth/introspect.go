@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenInfo describes an access token, see IntrospectToken. Only Active is
+// set for a token that cannot be used.
+type TokenInfo struct {
+	Active    bool
+	UserID    uuid.UUID
+	Email     string
+	UserType  domain.UserType
+	AdminRole string
+	ExpiresAt time.Time
+}
+
+// IntrospectToken checks an access token for another service: it must be
+// signed with the current or a previous JWT secret, unexpired, not revoked,
+// not issued on a revoked device and belong to an active user. The error is
+// for failures to check, never for a bad token.
+func (s *Service) IntrospectToken(ctx context.Context, tokenString string) (*TokenInfo, error) {
+	inactive := &TokenInfo{}
+	claims, ok := s.parseAccessToken(tokenString)
+	if !ok {
+		return inactive, nil
+	}
+	if s.blacklist != nil {
+		revoked, err := s.blacklist.IsBlacklisted(ctx, tokenString)
+		if err != nil {
+			return nil, kyderrors.Wrap(err, "failed to check token revocation")
+		}
+		if revoked {
+			return inactive, nil
+		}
+		if raw, ok := claims["device_id"].(string); ok {
+			deviceID, err := uuid.Parse(raw)
+			if err != nil {
+				return inactive, nil
+			}
+			revoked, err := s.blacklist.IsBlacklisted(ctx, domain.DeviceRevocationKey(deviceID))
+			if err != nil {
+				return nil, kyderrors.Wrap(err, "failed to check device revocation")
+			}
+			if revoked {
+				return inactive, nil
+			}
+		}
+	}
+
+	raw, _ := claims["user_id"].(string)
+	userID, err := uuid.Parse(raw)
+	if err != nil {
+		return inactive, nil
+	}
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, kyderrors.ErrUserNotFound) {
+			return inactive, nil
+		}
+		return nil, kyderrors.Wrap(err, "failed to load token user")
+	}
+	if !user.IsActive {
+		return inactive, nil
+	}
+
+	info := &TokenInfo{
+		Active:   true,
+		UserID:   user.ID,
+		Email:    user.Email,
+		UserType: user.UserType,
+	}
+	info.AdminRole, _ = claims["admin_role"].(string)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		info.ExpiresAt = exp.Time
+	}
+	return info, nil
+}
+
+// parseAccessToken verifies tokenString against each configured secret and
+// returns its claims if it is an access token.
+func (s *Service) parseAccessToken(tokenString string) (jwt.MapClaims, bool) {
+	for _, secret := range s.jwtSecrets {
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(secret), nil
+		})
+		if err != nil || !token.Valid {
+			continue
+		}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, false
+		}
+		// Verification and reset links are signed with the same secrets
+		// but are not access tokens.
+		if _, scoped := claims["purpose"]; scoped {
+			return nil, false
+		}
+		return claims, true
+	}
+	return nil, false
+}
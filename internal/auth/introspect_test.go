@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type stubBlacklist struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (b *stubBlacklist) Blacklist(ctx context.Context, token string, expiration time.Duration) error {
+	b.revoked[token] = true
+	return nil
+}
+
+func (b *stubBlacklist) IsBlacklisted(ctx context.Context, token string) (bool, error) {
+	return b.revoked[token], b.err
+}
+
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestIntrospectToken(t *testing.T) {
+	user := &domain.User{ID: uuid.New(), Email: "ops@example.com", UserType: domain.UserTypeAdmin, IsActive: true}
+	blocked := &domain.User{ID: uuid.New(), Email: "blocked@example.com", UserType: domain.UserTypeIndividual}
+	repo := new(MockRepository)
+	repo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+	repo.On("FindByID", mock.Anything, blocked.ID).Return(blocked, nil)
+	repo.On("FindByID", mock.Anything, mock.Anything).Return(nil, kyderrors.ErrUserNotFound)
+
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	access := func(secret string, id uuid.UUID) string {
+		return signToken(t, secret, jwt.MapClaims{
+			"user_id":    id.String(),
+			"email":      "ops@example.com",
+			"user_type":  "admin",
+			"admin_role": "super_admin",
+			"exp":        exp.Unix(),
+		})
+	}
+	blacklist := &stubBlacklist{revoked: map[string]bool{}}
+	service := NewService(repo, blacklist, "current", time.Hour).WithAdditionalJWTSecrets([]string{"previous"})
+	ctx := context.Background()
+
+	t.Run("active", func(t *testing.T) {
+		info, err := service.IntrospectToken(ctx, access("previous", user.ID))
+		require.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, user.ID, info.UserID)
+		assert.Equal(t, domain.UserTypeAdmin, info.UserType)
+		assert.Equal(t, "super_admin", info.AdminRole)
+		assert.True(t, exp.Equal(info.ExpiresAt))
+	})
+
+	inactive := map[string]string{
+		"wrong secret":   access("other", user.ID),
+		"expired":        signToken(t, "current", jwt.MapClaims{"user_id": user.ID.String(), "exp": time.Now().Add(-time.Minute).Unix()}),
+		"reset link":     signToken(t, "current", jwt.MapClaims{"user_id": user.ID.String(), "purpose": "password_reset", "exp": exp.Unix()}),
+		"blocked user":   access("current", blocked.ID),
+		"unknown user":   access("current", uuid.New()),
+		"malformed":      "not-a-token",
+		"missing claims": signToken(t, "current", jwt.MapClaims{"exp": exp.Unix()}),
+	}
+	for name, token := range inactive {
+		t.Run(name, func(t *testing.T) {
+			info, err := service.IntrospectToken(ctx, token)
+			require.NoError(t, err)
+			assert.False(t, info.Active)
+			assert.Equal(t, uuid.Nil, info.UserID)
+		})
+	}
+
+	t.Run("revoked", func(t *testing.T) {
+		token := access("current", user.ID)
+		blacklist.revoked[token] = true
+		info, err := service.IntrospectToken(ctx, token)
+		require.NoError(t, err)
+		assert.False(t, info.Active)
+	})
+
+	t.Run("revocation unknown", func(t *testing.T) {
+		blacklist.err = errors.New("redis down")
+		defer func() { blacklist.err = nil }()
+		_, err := service.IntrospectToken(ctx, access("current", user.ID))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/mailer"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const invitationTokenPurpose = "invitation"
+
+var (
+	// ErrInvalidInvitation is returned for invitation links that are
+	// malformed, expired or for an account that no longer exists.
+	ErrInvalidInvitation = errors.New("invalid or expired invitation")
+	// ErrInvitationAccepted is returned once the account has a password.
+	ErrInvitationAccepted = errors.New("invitation was already accepted")
+	// ErrInvitationsDisabled is returned when no invitation link is configured.
+	ErrInvitationsDisabled = errors.New("invitations are not configured")
+)
+
+// WithInvitations lets staff create provisional accounts whose holders are
+// emailed a link, valid for expiry, to set a password.
+func (s *Service) WithInvitations(baseURL string, expiry time.Duration) *Service {
+	s.invitationBaseURL = baseURL
+	s.invitationExpiry = expiry
+	return s
+}
+
+// Invite creates a provisional account for user and emails its holder an
+// invitation. The account has no password and cannot sign in until the
+// invitation is accepted; whatever KYC data it carries stays pending until
+// verified like any other.
+func (s *Service) Invite(ctx context.Context, user *domain.User) error {
+	if s.mailer == nil || s.invitationBaseURL == "" {
+		return ErrInvitationsDisabled
+	}
+	exists, err := s.repo.ExistsByEmail(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return kyderrors.ErrUserAlreadyExists
+	}
+
+	now := time.Now()
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	if user.UserType == "" {
+		user.UserType = domain.UserTypeIndividual
+	}
+	user.PasswordHash = ""
+	user.KYCLevel = 0
+	user.KYCStatus = domain.KYCStatusPending
+	user.RiskScore = decimal.Zero
+	user.IsActive = false
+	user.EmailVerified = false
+	user.CreatedAt, user.UpdatedAt = now, now
+
+	if err := s.repo.Create(ctx, user); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return kyderrors.ErrUserAlreadyExists
+		}
+		return err
+	}
+	return s.SendInvitation(ctx, user)
+}
+
+// SendInvitation emails a fresh invitation link for a provisional account.
+func (s *Service) SendInvitation(ctx context.Context, user *domain.User) error {
+	if s.mailer == nil || s.invitationBaseURL == "" {
+		return ErrInvitationsDisabled
+	}
+	claims := jwt.MapClaims{
+		"user_id": user.ID.String(),
+		"purpose": invitationTokenPurpose,
+		"exp":     time.Now().Add(s.invitationExpiry).Unix(),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.jwtSecret))
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s?token=%s", s.invitationBaseURL, signed)
+
+	if s.bypassVerification {
+		fmt.Printf("\n[DEV] INVITATION LINK for %s: %s\n\n", user.Email, link)
+		return nil
+	}
+
+	body := fmt.Sprintf(`<p>Hello %s,</p>
+<p>An account has been created for you. Click the link below to choose a password and finish setting it up:</p>
+<p><a href="%s">%s</a></p>
+<p>This link expires on %s.</p>`,
+		user.FirstName, link, link, time.Now().Add(s.invitationExpiry).UTC().Format("2 January 2006"))
+
+	return s.mailer.SendKind(ctx, mailer.KindInvitation, user.Email, "You're invited to open an account", body)
+}
+
+// Invitation returns the provisional account an invitation is for, so the
+// signup form can be pre-filled.
+func (s *Service) Invitation(ctx context.Context, tokenString string) (*domain.User, error) {
+	return s.parseInvitationToken(ctx, tokenString)
+}
+
+// AcceptInvitation sets the account's password, activates it and signs the
+// holder in. Following the emailed link proves the email address.
+func (s *Service) AcceptInvitation(ctx context.Context, tokenString, password string) (*TokenResponse, error) {
+	user, err := s.parseInvitationToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setPassword(ctx, user, password); err != nil {
+		return nil, err
+	}
+	user.IsActive = true
+	user.EmailVerified = true
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.generateTokens(user)
+}
+
+// parseInvitationToken returns the provisional account an invitation was
+// issued for.
+func (s *Service) parseInvitationToken(ctx context.Context, tokenString string) (*domain.User, error) {
+	for _, secret := range s.jwtSecrets {
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(secret), nil
+		})
+		if err != nil || !token.Valid {
+			continue
+		}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			break
+		}
+		if purpose, _ := claims["purpose"].(string); purpose != invitationTokenPurpose {
+			break
+		}
+		sub, _ := claims["user_id"].(string)
+		id, err := uuid.Parse(sub)
+		if err != nil {
+			break
+		}
+		user, err := s.repo.FindByID(ctx, id)
+		if err != nil || user == nil {
+			break
+		}
+		if user.IsActive || user.PasswordHash != "" {
+			return nil, ErrInvitationAccepted
+		}
+		return user, nil
+	}
+	return nil, ErrInvalidInvitation
+}
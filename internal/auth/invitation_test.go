@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/mailer"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteAndAccept(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	sender := new(MockSender)
+	m := &mailer.Mailer{}
+	m.WithSender(sender)
+	service := NewService(repo, nil, "secret", time.Hour).
+		WithEmailVerification(m, "http://verify", time.Hour, false).
+		WithInvitations("http://invite", 24*time.Hour)
+
+	var created *domain.User
+	repo.On("ExistsByEmail", ctx, "employee@example.com").Return(false, nil).Once()
+	repo.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(1).(*domain.User)
+	}).Return(nil).Once()
+	var link string
+	sender.On("Send", "employee@example.com", "You're invited to open an account", mock.Anything).Run(func(args mock.Arguments) {
+		link = verificationLink.FindStringSubmatch(args.String(2))[1]
+	}).Return(nil).Once()
+
+	require.NoError(t, service.Invite(ctx, &domain.User{Email: "employee@example.com", FirstName: "Ada", CountryCode: "MW"}))
+	require.NotNil(t, created)
+	assert.False(t, created.IsActive, "provisional accounts cannot sign in")
+	assert.Empty(t, created.PasswordHash)
+	assert.Equal(t, domain.KYCStatusPending, created.KYCStatus)
+	assert.Equal(t, domain.UserTypeIndividual, created.UserType)
+
+	repo.On("FindByID", ctx, created.ID).Return(created, nil)
+	invited, err := service.Invitation(ctx, link)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", invited.FirstName)
+
+	repo.On("Update", ctx, mock.Anything).Return(nil).Once()
+	tokens, err := service.AcceptInvitation(ctx, link, "Str0ng!Passw0rd")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.True(t, created.IsActive)
+	assert.True(t, created.EmailVerified, "following the link proves the address")
+
+	_, err = service.AcceptInvitation(ctx, link, "Str0ng!Passw0rd")
+	assert.Equal(t, ErrInvitationAccepted, err)
+	_, err = service.Invitation(ctx, "not-a-token")
+	assert.Equal(t, ErrInvalidInvitation, err)
+
+	repo.On("ExistsByEmail", ctx, "taken@example.com").Return(true, nil).Once()
+	assert.Equal(t, kyderrors.ErrUserAlreadyExists, service.Invite(ctx, &domain.User{Email: "taken@example.com"}))
+	repo.AssertExpectations(t)
+}
+
+func TestInvitationRejectsOtherTokens(t *testing.T) {
+	repo := new(MockRepository)
+	service := NewService(repo, nil, "secret", time.Hour).WithInvitations("http://invite", time.Hour)
+
+	// A password reset link must not activate a provisional account
+	claims := jwt.MapClaims{
+		"user_id": uuid.New().String(),
+		"purpose": "password_reset",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	_, err := service.AcceptInvitation(context.Background(), token, "Str0ng!Passw0rd")
+	assert.Equal(t, ErrInvalidInvitation, err)
+	repo.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/mailer"
+)
+
+// sendNewDeviceAlert emails the user about a sign-in from a device they have
+// not used before. It is sent in the background so login latency does not
+// depend on the mail server.
+func (s *Service) sendNewDeviceAlert(user *domain.User, req *LoginRequest, at time.Time) {
+	if s.mailer == nil {
+		return
+	}
+	device := req.DeviceName
+	if device == "" {
+		device = "Unknown device"
+	}
+	location := req.CountryCode
+	if location == "" {
+		location = "Unknown location"
+	}
+	body := fmt.Sprintf(`<p>Hello %s,</p>
+<p>Your KYD account was just signed in to from a new device:</p>
+<ul>
+<li>Time: %s</li>
+<li>Device: %s</li>
+<li>IP address: %s</li>
+<li>Approximate location: %s</li>
+</ul>
+<p>If this was you, no action is needed. If not, remove the device from your devices to sign it out, change your password immediately and contact support.</p>
+<p>You can turn these alerts off in your profile settings.</p>`,
+		html.EscapeString(user.FirstName), at.UTC().Format("2006-01-02 15:04 MST"),
+		html.EscapeString(device), html.EscapeString(req.IPAddress), html.EscapeString(location))
+
+	to := user.Email
+	go func() {
+		_ = s.mailer.SendKind(context.Background(), mailer.KindLoginAlert, to, "New sign-in to your KYD account", body)
+	}()
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/mailer"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLogin_NewDeviceAlert(t *testing.T) {
+	ctx := context.Background()
+	policy := testPasswordPolicy()
+	hash, _ := policy.hashPassword("Correct123!")
+
+	login := func(user *domain.User, knownDevice bool) *MockSender {
+		repo := new(MockRepository)
+		sender := new(MockSender)
+		m := &mailer.Mailer{}
+		m.WithSender(sender)
+		service := NewService(repo, nil, "secret", time.Hour).
+			WithPasswordPolicy(policy).
+			WithEmailVerification(m, "http://verify", time.Hour, false)
+
+		repo.On("FindByEmail", ctx, user.Email).Return(user, nil)
+		repo.On("Update", ctx, mock.Anything).Return(nil)
+		repo.On("HasDevice", ctx, user.ID, "device-1").Return(knownDevice, nil).Maybe()
+		repo.On("AddDevice", ctx, mock.Anything).Return(nil)
+		sender.On("Send", user.Email, "New sign-in to your KYD account", mock.Anything).Return(nil).Maybe()
+
+		_, err := service.Login(ctx, &LoginRequest{Email: user.Email, Password: "Correct123!", DeviceID: "device-1", DeviceName: "Pixel 8"})
+		assert.NoError(t, err)
+		return sender
+	}
+	newUser := func() *domain.User {
+		last := time.Now().Add(-24 * time.Hour)
+		return &domain.User{ID: uuid.New(), Email: "user@example.com", IsActive: true, PasswordHash: hash, PasswordVersion: policy.Version, LastLogin: &last}
+	}
+
+	sender := login(newUser(), false)
+	// The alert is sent in the background.
+	for i := 0; i < 100 && len(sender.Calls) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, sender.Calls, 1)
+
+	// Known devices, first logins and opted-out users get no alert.
+	known := login(newUser(), true)
+	first := newUser()
+	first.LastLogin = nil
+	firstSender := login(first, false)
+	optedOut := newUser()
+	optedOut.LoginAlertsDisabled = true
+	optedOutSender := login(optedOut, false)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, known.Calls)
+	assert.Empty(t, firstSender.Calls)
+	assert.Empty(t, optedOutSender.Calls)
+}
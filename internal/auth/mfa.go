@@ -0,0 +1,513 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/mailer"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+)
+
+var (
+	// ErrInvalidMFAToken is returned for a missing, expired or tampered
+	// login challenge; the user must sign in with their password again.
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+	// ErrMFAMethodUnavailable is returned for a method the user cannot use,
+	// and at login when MFA is required but the user has no method at all.
+	ErrMFAMethodUnavailable = errors.New("mfa method not available")
+	ErrMFANotConfigured     = errors.New("mfa is not configured")
+	ErrInvalidMFAPolicy     = errors.New("invalid mfa policy")
+)
+
+const (
+	mfaTokenPurpose     = "mfa"
+	defaultChallengeTTL = 5 * time.Minute
+	// Backup codes are 10 characters from an alphabet without look-alikes,
+	// shown as two groups of five.
+	backupCodeLength   = 10
+	backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// MFARepository stores the one-time codes sent at login, backup codes and
+// the MFA policy admins set.
+type MFARepository interface {
+	CreateMFACode(ctx context.Context, c *domain.MFACode) error
+	// LatestMFACode returns nil when no code has been sent.
+	LatestMFACode(ctx context.Context, userID uuid.UUID) (*domain.MFACode, error)
+	CountMFACodesSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	IncrementMFACodeAttempts(ctx context.Context, id uuid.UUID) error
+	ConsumeMFACode(ctx context.Context, id uuid.UUID) error
+
+	// ReplaceBackupCodes discards the user's backup codes for new ones.
+	ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, hashes []string) error
+	// UseBackupCode spends an unused code, reporting false when there is
+	// no such code.
+	UseBackupCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error)
+	CountBackupCodes(ctx context.Context, userID uuid.UUID) (int, error)
+
+	// GetMFAPolicy returns nil until an admin has set one.
+	GetMFAPolicy(ctx context.Context) (*domain.MFAPolicy, error)
+	SetMFAPolicy(ctx context.Context, p *domain.MFAPolicy) error
+}
+
+// MFASettings tunes two-factor login. Default is the policy until an admin
+// sets one.
+type MFASettings struct {
+	Default         domain.MFAPolicy
+	ChallengeTTL    time.Duration
+	CodeExpiry      time.Duration
+	CodeMaxAttempts int
+	BackupCodes     int
+}
+
+// MFASettingsFromConfig builds the MFA settings.
+func MFASettingsFromConfig(cfg config.MFAConfig) MFASettings {
+	return MFASettings{
+		Default: domain.MFAPolicy{
+			RequiredForAdmins: cfg.RequiredForAdmins,
+			RequiredKYCLevel:  cfg.RequiredKYCLevel,
+		},
+		ChallengeTTL:    cfg.ChallengeTTL,
+		CodeExpiry:      cfg.CodeExpiry,
+		CodeMaxAttempts: cfg.CodeMaxAttempts,
+		BackupCodes:     cfg.BackupCodes,
+	}
+}
+
+// WithMFA enables SMS and email login codes, backup codes and the policy
+// forcing MFA on some accounts. Without it only users who enrolled TOTP get
+// a second step at login.
+func (s *Service) WithMFA(settings MFASettings, repo MFARepository) *Service {
+	s.mfa = settings
+	s.mfaStore = repo
+	return s
+}
+
+// MFAChallenge is the second login step, returned instead of tokens when
+// the password was right but a second factor is needed. The client finishes
+// with VerifyMFA, first calling SendMFACode for the sms and email methods.
+type MFAChallenge struct {
+	Required  bool               `json:"mfa_required"`
+	Token     string             `json:"mfa_token"`
+	Methods   []domain.MFAMethod `json:"mfa_methods"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
+
+// MFAVerifyRequest completes a login challenge. The device fields are
+// filled in from the request like LoginRequest's.
+type MFAVerifyRequest struct {
+	MFAToken    string           `json:"mfa_token" validate:"required"`
+	Method      domain.MFAMethod `json:"method" validate:"required"`
+	Code        string           `json:"code" validate:"required"`
+	DeviceID    string           `json:"device_id"`
+	DeviceName  string           `json:"-"`
+	IPAddress   string           `json:"-"`
+	CountryCode string           `json:"country_code"`
+}
+
+// MFAStatus is what a user sees of their own two-factor setup.
+type MFAStatus struct {
+	Required             bool               `json:"required"`
+	TOTPEnabled          bool               `json:"totp_enabled"`
+	Methods              []domain.MFAMethod `json:"methods"`
+	BackupCodesRemaining int                `json:"backup_codes_remaining"`
+}
+
+// MFAPolicy returns the policy in force.
+func (s *Service) MFAPolicy(ctx context.Context) (*domain.MFAPolicy, error) {
+	def := s.mfa.Default
+	if s.mfaStore == nil {
+		return &def, nil
+	}
+	p, err := s.mfaStore.GetMFAPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return &def, nil
+	}
+	return p, nil
+}
+
+// SetMFAPolicy replaces the policy on behalf of an admin. It applies from
+// the next login.
+func (s *Service) SetMFAPolicy(ctx context.Context, p *domain.MFAPolicy, adminID uuid.UUID) error {
+	if s.mfaStore == nil {
+		return ErrMFANotConfigured
+	}
+	if p.RequiredKYCLevel < 0 {
+		return fmt.Errorf("%w: required_kyc_level must not be negative", ErrInvalidMFAPolicy)
+	}
+	p.UpdatedBy = &adminID
+	p.UpdatedAt = time.Now()
+	return s.mfaStore.SetMFAPolicy(ctx, p)
+}
+
+// mfaRequired reports whether user must pass a second factor to sign in.
+func (s *Service) mfaRequired(ctx context.Context, user *domain.User) (bool, error) {
+	if user.IsTOTPEnabled {
+		return true, nil
+	}
+	p, err := s.MFAPolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return p.Requires(user), nil
+}
+
+// mfaMethods lists the second factors user can use right now.
+func (s *Service) mfaMethods(ctx context.Context, user *domain.User) ([]domain.MFAMethod, error) {
+	var methods []domain.MFAMethod
+	if user.IsTOTPEnabled && user.TOTPSecret != nil {
+		methods = append(methods, domain.MFAMethodTOTP)
+	}
+	if s.mfaStore == nil {
+		return methods, nil
+	}
+	// Codes only go to a number or address the user has proven they own.
+	if s.sms != nil && user.Phone != "" && user.PhoneVerified {
+		methods = append(methods, domain.MFAMethodSMS)
+	}
+	if s.mailer != nil && user.EmailVerified {
+		methods = append(methods, domain.MFAMethodEmail)
+	}
+	n, err := s.mfaStore.CountBackupCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		methods = append(methods, domain.MFAMethodBackupCode)
+	}
+	return methods, nil
+}
+
+func hasMFAMethod(methods []domain.MFAMethod, m domain.MFAMethod) bool {
+	for _, have := range methods {
+		if have == m {
+			return true
+		}
+	}
+	return false
+}
+
+// mfaChallenge starts the second login step for user.
+func (s *Service) mfaChallenge(ctx context.Context, user *domain.User, changeRequired bool) (*TokenResponse, error) {
+	methods, err := s.mfaMethods(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, ErrMFAMethodUnavailable
+	}
+	ttl := s.mfa.ChallengeTTL
+	if ttl <= 0 {
+		ttl = defaultChallengeTTL
+	}
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	// The challenge names the user in "sub", not "user_id", so it is never
+	// accepted as an access token.
+	claims := jwt.MapClaims{
+		"sub":     user.ID.String(),
+		"purpose": mfaTokenPurpose,
+		"pcr":     changeRequired,
+		"exp":     expiresAt.Unix(),
+		"iat":     now.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.signingSecret()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign mfa token: %w", err)
+	}
+	return &TokenResponse{MFA: &MFAChallenge{
+		Required:  true,
+		Token:     token,
+		Methods:   methods,
+		ExpiresAt: expiresAt,
+	}}, nil
+}
+
+// parseMFAToken returns the user a login challenge was issued to, and
+// whether their password must be changed.
+func (s *Service) parseMFAToken(ctx context.Context, tokenString string) (*domain.User, bool, error) {
+	for _, secret := range s.jwtSecrets {
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(secret), nil
+		})
+		if err != nil || !token.Valid {
+			continue
+		}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			break
+		}
+		if purpose, _ := claims["purpose"].(string); purpose != mfaTokenPurpose {
+			break
+		}
+		sub, _ := claims["sub"].(string)
+		id, err := uuid.Parse(sub)
+		if err != nil {
+			break
+		}
+		user, err := s.repo.FindByID(ctx, id)
+		if err != nil || !user.IsActive {
+			break
+		}
+		changeRequired, _ := claims["pcr"].(bool)
+		return user, changeRequired, nil
+	}
+	return nil, false, ErrInvalidMFAToken
+}
+
+// SendMFACode sends a login code by SMS or email for a challenge, subject to
+// the same resend limits as verification codes.
+func (s *Service) SendMFACode(ctx context.Context, mfaToken string, method domain.MFAMethod) error {
+	user, _, err := s.parseMFAToken(ctx, mfaToken)
+	if err != nil {
+		return err
+	}
+	return s.sendMFACode(ctx, user, method, "sign-in",
+		"If you did not just try to sign in, change your password now: someone knows it.")
+}
+
+// sendMFACode sends user a one-time code by SMS or email, subject to the
+// resend limits. purpose names what the code is for in the message and
+// warning is what the email tells a user who did not ask for it.
+func (s *Service) sendMFACode(ctx context.Context, user *domain.User, method domain.MFAMethod, purpose, warning string) error {
+	if method != domain.MFAMethodSMS && method != domain.MFAMethodEmail {
+		return ErrMFAMethodUnavailable
+	}
+	methods, err := s.mfaMethods(ctx, user)
+	if err != nil {
+		return err
+	}
+	if !hasMFAMethod(methods, method) {
+		return ErrMFAMethodUnavailable
+	}
+
+	now := time.Now()
+	p := s.verificationPolicy
+	if p.ResendCooldown > 0 {
+		latest, err := s.mfaStore.LatestMFACode(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if latest != nil && now.Sub(latest.CreatedAt) < p.ResendCooldown {
+			return kyderrors.ErrVerificationThrottled
+		}
+	}
+	if p.MaxResendsPerDay > 0 {
+		sent, err := s.mfaStore.CountMFACodesSince(ctx, user.ID, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if sent >= p.MaxResendsPerDay {
+			return kyderrors.ErrVerificationThrottled
+		}
+	}
+
+	code, err := generatePhoneCode()
+	if err != nil {
+		return err
+	}
+	c := &domain.MFACode{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Method:    method,
+		CodeHash:  hashMFACode(user.ID, method, code),
+		ExpiresAt: now.Add(s.mfa.CodeExpiry),
+		CreatedAt: now,
+	}
+	if err := s.mfaStore.CreateMFACode(ctx, c); err != nil {
+		return err
+	}
+	minutes := int(s.mfa.CodeExpiry.Minutes())
+	if method == domain.MFAMethodSMS {
+		msg := fmt.Sprintf("Your KYD %s code is %s. It expires in %d minutes. Never share this code.", purpose, code, minutes)
+		return s.sms.Send(ctx, user.Phone, msg)
+	}
+	body := fmt.Sprintf(`<p>Hello %s,</p>
+<p>Your KYD %s code is <strong>%s</strong>. It expires in %d minutes.</p>
+<p>%s</p>`,
+		html.EscapeString(user.FirstName), purpose, code, minutes, warning)
+	return s.mailer.SendKind(ctx, mailer.KindMFACode, user.Email, "Your KYD "+purpose+" code", body)
+}
+
+// VerifyMFA completes a login challenge and issues tokens. Wrong codes count
+// towards the same lockout as step-up proofs.
+func (s *Service) VerifyMFA(ctx context.Context, req *MFAVerifyRequest) (*TokenResponse, error) {
+	user, changeRequired, err := s.parseMFAToken(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, user, req.Method, req.Code); err != nil {
+		return nil, err
+	}
+	return s.completeLogin(ctx, user, &LoginRequest{
+		Email:       user.Email,
+		DeviceID:    req.DeviceID,
+		DeviceName:  req.DeviceName,
+		IPAddress:   req.IPAddress,
+		CountryCode: req.CountryCode,
+	}, changeRequired, true)
+}
+
+// verifySecondFactor checks code by method, counting and auditing wrong
+// codes. ErrStepUpLocked is returned while too many wrong proofs lock it.
+func (s *Service) verifySecondFactor(ctx context.Context, user *domain.User, method domain.MFAMethod, code string) error {
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		s.auditProofFailure(ctx, user, "MFA_FAILED", kyderrors.ErrStepUpLocked)
+		return kyderrors.ErrStepUpLocked
+	}
+	methods, err := s.mfaMethods(ctx, user)
+	if err != nil {
+		return err
+	}
+	if !hasMFAMethod(methods, method) {
+		return ErrMFAMethodUnavailable
+	}
+
+	err = s.checkMFACode(ctx, user, method, code)
+	if errors.Is(err, kyderrors.ErrTooManyCodeAttempts) {
+		// The code is spent; a new one must be sent
+		return err
+	}
+	if err != nil {
+		return s.proofFailed(ctx, user, "MFA_FAILED", err)
+	}
+	if s.loginSecurity != nil && (user.FailedLoginAttempts > 0 || user.LockedUntil != nil) {
+		_ = s.loginSecurity.UpdateLoginSecurity(ctx, user.ID, 0, nil)
+	}
+	return nil
+}
+
+func (s *Service) checkMFACode(ctx context.Context, user *domain.User, method domain.MFAMethod, code string) error {
+	switch method {
+	case domain.MFAMethodTOTP:
+		if !totp.Validate(code, *user.TOTPSecret) {
+			return kyderrors.ErrInvalidTOTP
+		}
+		return nil
+	case domain.MFAMethodBackupCode:
+		ok, err := s.mfaStore.UseBackupCode(ctx, user.ID, hashBackupCode(user.ID, code))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return kyderrors.ErrInvalidTOTP
+		}
+		return nil
+	}
+
+	latest, err := s.mfaStore.LatestMFACode(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if latest == nil || latest.Method != method || latest.ConsumedAt != nil || time.Now().After(latest.ExpiresAt) {
+		return kyderrors.ErrInvalidTOTP
+	}
+	if s.mfa.CodeMaxAttempts > 0 && latest.Attempts >= s.mfa.CodeMaxAttempts {
+		return kyderrors.ErrTooManyCodeAttempts
+	}
+	expected := hashMFACode(user.ID, method, code)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(latest.CodeHash)) != 1 {
+		if err := s.mfaStore.IncrementMFACodeAttempts(ctx, latest.ID); err != nil {
+			return err
+		}
+		return kyderrors.ErrInvalidTOTP
+	}
+	return s.mfaStore.ConsumeMFACode(ctx, latest.ID)
+}
+
+// RegenerateBackupCodes replaces the user's backup codes with fresh ones.
+// The codes are returned once; only their hashes are kept.
+func (s *Service) RegenerateBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	if s.mfaStore == nil || s.mfa.BackupCodes <= 0 {
+		return nil, ErrMFANotConfigured
+	}
+	codes := make([]string, s.mfa.BackupCodes)
+	hashes := make([]string, len(codes))
+	for i := range codes {
+		code, err := generateBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashBackupCode(userID, code)
+	}
+	if err := s.mfaStore.ReplaceBackupCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// MFAStatus reports the user's two-factor setup.
+func (s *Service) MFAStatus(ctx context.Context, userID uuid.UUID) (*MFAStatus, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.mfaRequired(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := s.mfaMethods(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	st := &MFAStatus{Required: required, TOTPEnabled: user.IsTOTPEnabled, Methods: methods}
+	if s.mfaStore != nil {
+		if st.BackupCodesRemaining, err = s.mfaStore.CountBackupCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func generateBackupCode() (string, error) {
+	b := make([]byte, backupCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// 256 is not a multiple of the alphabet size; the bias is negligible
+	// for single-use codes behind a lockout.
+	for i := range b {
+		b[i] = backupCodeAlphabet[int(b[i])%len(backupCodeAlphabet)]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// hashBackupCode ignores case, spaces and dashes, which users add and drop
+// when typing codes back.
+func hashBackupCode(userID uuid.UUID, code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	sum := sha256.Sum256([]byte(userID.String() + "|backup|" + normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashMFACode binds a login code to the user and the channel it was sent on.
+func hashMFACode(userID uuid.UUID, method domain.MFAMethod, code string) string {
+	sum := sha256.Sum256([]byte(userID.String() + "|" + string(method) + "|" + code))
+	return hex.EncodeToString(sum[:])
+}
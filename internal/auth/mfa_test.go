@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryMFARepository struct {
+	codes   []*domain.MFACode
+	backup  map[string]bool // hash -> used
+	policy  *domain.MFAPolicy
+	ownerOf map[string]uuid.UUID
+}
+
+func newMemoryMFARepository() *memoryMFARepository {
+	return &memoryMFARepository{backup: make(map[string]bool), ownerOf: make(map[string]uuid.UUID)}
+}
+
+func (r *memoryMFARepository) CreateMFACode(_ context.Context, c *domain.MFACode) error {
+	cp := *c
+	r.codes = append(r.codes, &cp)
+	return nil
+}
+
+func (r *memoryMFARepository) LatestMFACode(_ context.Context, userID uuid.UUID) (*domain.MFACode, error) {
+	var latest *domain.MFACode
+	for _, c := range r.codes {
+		if c.UserID == userID {
+			latest = c
+		}
+	}
+	return latest, nil
+}
+
+func (r *memoryMFARepository) CountMFACodesSince(_ context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	n := 0
+	for _, c := range r.codes {
+		if c.UserID == userID && !c.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryMFARepository) IncrementMFACodeAttempts(_ context.Context, id uuid.UUID) error {
+	for _, c := range r.codes {
+		if c.ID == id {
+			c.Attempts++
+		}
+	}
+	return nil
+}
+
+func (r *memoryMFARepository) ConsumeMFACode(_ context.Context, id uuid.UUID) error {
+	for _, c := range r.codes {
+		if c.ID == id {
+			now := time.Now()
+			c.ConsumedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryMFARepository) ReplaceBackupCodes(_ context.Context, userID uuid.UUID, hashes []string) error {
+	for h, owner := range r.ownerOf {
+		if owner == userID {
+			delete(r.backup, h)
+			delete(r.ownerOf, h)
+		}
+	}
+	for _, h := range hashes {
+		r.backup[h] = false
+		r.ownerOf[h] = userID
+	}
+	return nil
+}
+
+func (r *memoryMFARepository) UseBackupCode(_ context.Context, userID uuid.UUID, hash string) (bool, error) {
+	used, ok := r.backup[hash]
+	if !ok || used || r.ownerOf[hash] != userID {
+		return false, nil
+	}
+	r.backup[hash] = true
+	return true, nil
+}
+
+func (r *memoryMFARepository) CountBackupCodes(_ context.Context, userID uuid.UUID) (int, error) {
+	n := 0
+	for h, used := range r.backup {
+		if !used && r.ownerOf[h] == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryMFARepository) GetMFAPolicy(_ context.Context) (*domain.MFAPolicy, error) {
+	return r.policy, nil
+}
+
+func (r *memoryMFARepository) SetMFAPolicy(_ context.Context, p *domain.MFAPolicy) error {
+	cp := *p
+	r.policy = &cp
+	return nil
+}
+
+type mfaFixture struct {
+	service *Service
+	repo    *MockRepository
+	store   *memoryMFARepository
+	sms     *recordingSMSSender
+	user    *domain.User
+}
+
+func newMFAFixture(t *testing.T, settings MFASettings) *mfaFixture {
+	policy := testPasswordPolicy()
+	hash, err := policy.hashPassword("Correct123!")
+	require.NoError(t, err)
+
+	f := &mfaFixture{
+		repo:  new(MockRepository),
+		store: newMemoryMFARepository(),
+		sms:   &recordingSMSSender{},
+		user: &domain.User{
+			ID: uuid.New(), Email: "user@example.com", Phone: "+265991234567", PhoneVerified: true,
+			IsActive: true, PasswordHash: hash, PasswordVersion: policy.Version,
+		},
+	}
+	if settings.CodeExpiry == 0 {
+		settings.CodeExpiry = 5 * time.Minute
+	}
+	f.service = NewService(f.repo, nil, "secret", time.Hour).
+		WithPasswordPolicy(policy).
+		WithPhoneVerification(f.sms, &memoryPhoneCodeRepository{}, 5*time.Minute, 5).
+		WithMFA(settings, f.store)
+
+	f.repo.On("FindByEmail", mock.Anything, f.user.Email).Return(f.user, nil)
+	f.repo.On("FindByID", mock.Anything, f.user.ID).Return(f.user, nil)
+	f.repo.On("Update", mock.Anything, mock.Anything).Return(nil)
+	return f
+}
+
+func (f *mfaFixture) enrollTOTP(t *testing.T) string {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "KYD", AccountName: f.user.Email})
+	require.NoError(t, err)
+	secret := key.Secret()
+	f.user.TOTPSecret = &secret
+	f.user.IsTOTPEnabled = true
+	return secret
+}
+
+func (f *mfaFixture) login(t *testing.T, totpCode string) (*TokenResponse, error) {
+	return f.service.Login(context.Background(), &LoginRequest{Email: f.user.Email, Password: "Correct123!", TOTPCode: totpCode})
+}
+
+func TestLogin_NoSecondFactorWhenNotRequired(t *testing.T) {
+	f := newMFAFixture(t, MFASettings{})
+	resp, err := f.login(t, "")
+	require.NoError(t, err)
+	assert.Nil(t, resp.MFA)
+	assert.NotEmpty(t, resp.AccessToken)
+}
+
+func TestLogin_TOTPUserGetsChallenge(t *testing.T) {
+	ctx := context.Background()
+	f := newMFAFixture(t, MFASettings{})
+	secret := f.enrollTOTP(t)
+
+	resp, err := f.login(t, "")
+	require.NoError(t, err)
+	require.NotNil(t, resp.MFA)
+	assert.Empty(t, resp.AccessToken, "no tokens before the second factor")
+	assert.Equal(t, []domain.MFAMethod{domain.MFAMethodTOTP, domain.MFAMethodSMS}, resp.MFA.Methods)
+
+	// The challenge is not an access token.
+	_, ok := f.service.parseAccessToken(resp.MFA.Token)
+	assert.False(t, ok)
+
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodTOTP, Code: "000000"})
+	assert.ErrorIs(t, err, kyderrors.ErrInvalidTOTP)
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: "forged", Method: domain.MFAMethodTOTP, Code: "000000"})
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	tokens, err := f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodTOTP, Code: code})
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	// One-step login with the code still works.
+	resp, err = f.login(t, code)
+	require.NoError(t, err)
+	assert.Nil(t, resp.MFA)
+	assert.NotEmpty(t, resp.AccessToken)
+}
+
+func TestLogin_PolicyForcesSMSCode(t *testing.T) {
+	ctx := context.Background()
+	f := newMFAFixture(t, MFASettings{Default: domain.MFAPolicy{RequiredKYCLevel: 2}, CodeMaxAttempts: 3})
+	f.user.KYCLevel = 1
+
+	resp, err := f.login(t, "")
+	require.NoError(t, err)
+	assert.Nil(t, resp.MFA, "below the KYC level")
+
+	f.user.KYCLevel = 2
+	resp, err = f.login(t, "")
+	require.NoError(t, err)
+	require.NotNil(t, resp.MFA)
+	assert.Equal(t, []domain.MFAMethod{domain.MFAMethodSMS}, resp.MFA.Methods)
+	token := resp.MFA.Token
+
+	assert.ErrorIs(t, f.service.SendMFACode(ctx, token, domain.MFAMethodEmail), ErrMFAMethodUnavailable)
+	require.NoError(t, f.service.SendMFACode(ctx, token, domain.MFAMethodSMS))
+	code := f.sms.lastCode()
+
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: token, Method: domain.MFAMethodSMS, Code: "000000"})
+	assert.ErrorIs(t, err, kyderrors.ErrInvalidTOTP)
+	tokens, err := f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: token, Method: domain.MFAMethodSMS, Code: code})
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: token, Method: domain.MFAMethodSMS, Code: code})
+	assert.ErrorIs(t, err, kyderrors.ErrInvalidTOTP, "codes are single use")
+
+	// An admin-set policy replaces the configured one.
+	require.NoError(t, f.service.SetMFAPolicy(ctx, &domain.MFAPolicy{RequiredForAdmins: true}, uuid.New()))
+	resp, err = f.login(t, "")
+	require.NoError(t, err)
+	assert.Nil(t, resp.MFA)
+	assert.ErrorIs(t, f.service.SetMFAPolicy(ctx, &domain.MFAPolicy{RequiredKYCLevel: -1}, uuid.New()), ErrInvalidMFAPolicy)
+}
+
+func TestLogin_SMSCodeAttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	f := newMFAFixture(t, MFASettings{Default: domain.MFAPolicy{RequiredKYCLevel: 1}, CodeMaxAttempts: 2})
+	f.user.KYCLevel = 1
+	resp, err := f.login(t, "")
+	require.NoError(t, err)
+	require.NoError(t, f.service.SendMFACode(ctx, resp.MFA.Token, domain.MFAMethodSMS))
+	code := f.sms.lastCode()
+
+	for i := 0; i < 2; i++ {
+		_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodSMS, Code: "000000"})
+		assert.ErrorIs(t, err, kyderrors.ErrInvalidTOTP)
+	}
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodSMS, Code: code})
+	assert.ErrorIs(t, err, kyderrors.ErrTooManyCodeAttempts)
+}
+
+func TestLogin_ForcedWithoutAnyMethod(t *testing.T) {
+	f := newMFAFixture(t, MFASettings{Default: domain.MFAPolicy{RequiredForAdmins: true}})
+	f.user.UserType = domain.UserTypeAdmin
+	f.user.PhoneVerified = false
+
+	_, err := f.login(t, "")
+	assert.ErrorIs(t, err, ErrMFAMethodUnavailable)
+}
+
+func TestBackupCodes(t *testing.T) {
+	ctx := context.Background()
+	f := newMFAFixture(t, MFASettings{BackupCodes: 3})
+	f.enrollTOTP(t)
+	f.user.PhoneVerified = false
+
+	codes, err := f.service.RegenerateBackupCodes(ctx, f.user.ID)
+	require.NoError(t, err)
+	require.Len(t, codes, 3)
+	assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, codes[0])
+
+	resp, err := f.login(t, "")
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MFAMethod{domain.MFAMethodTOTP, domain.MFAMethodBackupCode}, resp.MFA.Methods)
+
+	// Case and dashes do not matter; each code works once.
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodBackupCode, Code: typed})
+	require.NoError(t, err)
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodBackupCode, Code: codes[0]})
+	assert.ErrorIs(t, err, kyderrors.ErrInvalidTOTP)
+
+	st, err := f.service.MFAStatus(ctx, f.user.ID)
+	require.NoError(t, err)
+	assert.True(t, st.Required)
+	assert.Equal(t, 2, st.BackupCodesRemaining)
+
+	// Regenerating voids the old codes.
+	_, err = f.service.RegenerateBackupCodes(ctx, f.user.ID)
+	require.NoError(t, err)
+	_, err = f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodBackupCode, Code: codes[1]})
+	assert.ErrorIs(t, err, kyderrors.ErrInvalidTOTP)
+}
+
+func TestVerifyMFA_LocksAfterRepeatedFailures(t *testing.T) {
+	ctx := context.Background()
+	f := newMFAFixture(t, MFASettings{})
+	secret := f.enrollTOTP(t)
+	audit := &memAudit{}
+	f.service.WithStepUpLockout(&memLoginSecurity{user: f.user}, audit, StepUpLockout{MaxAttempts: 3, Duration: time.Hour})
+
+	resp, err := f.login(t, "")
+	require.NoError(t, err)
+	verify := func(code string) error {
+		_, err := f.service.VerifyMFA(ctx, &MFAVerifyRequest{MFAToken: resp.MFA.Token, Method: domain.MFAMethodTOTP, Code: code})
+		return err
+	}
+	assert.ErrorIs(t, verify("000000"), kyderrors.ErrInvalidTOTP)
+	assert.ErrorIs(t, verify("000000"), kyderrors.ErrInvalidTOTP)
+	assert.ErrorIs(t, verify("000000"), kyderrors.ErrStepUpLocked)
+
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	assert.ErrorIs(t, verify(code), kyderrors.ErrStepUpLocked, "even the right code is refused while locked")
+	require.NotEmpty(t, audit.logs)
+	assert.Equal(t, "MFA_FAILED", audit.logs[0].Action)
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+// Argon2Params are the Argon2id cost parameters. They are encoded into every
+// hash (PHC string format), so each credential records how it was produced
+// and can be verified after the defaults change.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the OWASP recommendation for Argon2id.
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+var errMalformedHash = errors.New("malformed password hash")
+
+// hashPassword hashes a password with the policy's algorithm and parameters.
+func (p PasswordPolicy) hashPassword(password string) (string, error) {
+	if p.HashAlgorithm == HashAlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		return string(hash), err
+	}
+	salt := make([]byte, p.Argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	a := p.Argon2
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPassword checks a password against a stored bcrypt or Argon2id hash.
+// needsRehash reports that the hash was produced with a different algorithm
+// or weaker parameters than the policy now asks for.
+func (p PasswordPolicy) verifyPassword(encoded, password string) (ok, needsRehash bool) {
+	if strings.HasPrefix(encoded, "$argon2id$") {
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false
+		}
+		candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(key, candidate) != 1 {
+			return false, false
+		}
+		want := p.Argon2
+		return true, p.HashAlgorithm != HashAlgorithmArgon2id ||
+			params.Memory != want.Memory || params.Iterations != want.Iterations ||
+			params.Parallelism != want.Parallelism || params.KeyLength != want.KeyLength
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+		return false, false
+	}
+	if p.HashAlgorithm != HashAlgorithmBcrypt {
+		return true, true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return true, err != nil || cost < p.BcryptCost
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errMalformedHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// VerifyPassword reports whether password matches the user's stored hash.
+func (s *Service) VerifyPassword(encoded, password string) bool {
+	ok, _ := s.passwordPolicy.verifyPassword(encoded, password)
+	return ok
+}
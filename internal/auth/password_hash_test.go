@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPasswordPolicy keeps Argon2 cheap so tests stay fast.
+func testPasswordPolicy() PasswordPolicy {
+	p := DefaultPasswordPolicy()
+	p.Argon2.Memory = 1024
+	p.Argon2.Iterations = 1
+	p.Argon2.Parallelism = 1
+	return p
+}
+
+func TestArgon2id_HashAndVerify(t *testing.T) {
+	p := testPasswordPolicy()
+	hash, err := p.hashPassword("Correct123!")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	ok, rehash := p.verifyPassword(hash, "Correct123!")
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, _ = p.verifyPassword(hash, "Wrong123!")
+	assert.False(t, ok)
+
+	// Each hash carries its own salt.
+	other, _ := p.hashPassword("Correct123!")
+	assert.NotEqual(t, hash, other)
+
+	ok, _ = p.verifyPassword("$argon2id$v=19$m=1024$bad", "Correct123!")
+	assert.False(t, ok)
+}
+
+func TestVerifyPassword_FlagsOutdatedHashes(t *testing.T) {
+	p := testPasswordPolicy()
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("Correct123!"), bcrypt.MinCost)
+	ok, rehash := p.verifyPassword(string(legacy), "Correct123!")
+	assert.True(t, ok)
+	assert.True(t, rehash, "bcrypt hashes migrate to Argon2id")
+
+	old, _ := p.hashPassword("Correct123!")
+	p.Argon2.Iterations = 2
+	ok, rehash = p.verifyPassword(old, "Correct123!")
+	assert.True(t, ok, "hashes verify with the parameters they were made with")
+	assert.True(t, rehash)
+
+	p.HashAlgorithm = HashAlgorithmBcrypt
+	p.BcryptCost = bcrypt.MinCost
+	ok, rehash = p.verifyPassword(string(legacy), "Correct123!")
+	assert.True(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestLogin_MigratesBcryptHash(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	policy := testPasswordPolicy()
+	service := NewService(repo, nil, "secret", time.Hour).WithPasswordPolicy(policy)
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("Correct123!"), bcrypt.MinCost)
+	user := &domain.User{ID: uuid.New(), Email: "user@example.com", IsActive: true, PasswordHash: string(legacy), PasswordVersion: policy.Version}
+	repo.On("FindByEmail", ctx, user.Email).Return(user, nil)
+	repo.On("Update", ctx, mock.Anything).Return(nil)
+
+	resp, err := service.Login(ctx, &LoginRequest{Email: user.Email, Password: "Correct123!"})
+	assert.NoError(t, err)
+	assert.False(t, resp.PasswordChangeRequired)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+	assert.True(t, service.VerifyPassword(user.PasswordHash, "Correct123!"))
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordBreached is returned for passwords found in a public breach corpus.
+var ErrPasswordBreached = errors.New("has appeared in a data breach, choose a different password")
+
+// PasswordPolicy is the set of rules a new password must satisfy. Version
+// identifies the rule set a stored password was last checked against.
+type PasswordPolicy struct {
+	Version        int
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireNumber  bool
+	RequireSpecial bool
+	// DenyList holds lower-cased passwords that are always rejected.
+	DenyList map[string]struct{}
+
+	// HashAlgorithm selects how new passwords are hashed. Existing hashes of
+	// either algorithm are still accepted and upgraded on the next login.
+	HashAlgorithm string
+	Argon2        Argon2Params
+	BcryptCost    int
+}
+
+// DefaultPasswordPolicy is the policy applied when none is configured.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		Version:        1,
+		MinLength:      8,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireNumber:  true,
+		RequireSpecial: true,
+		HashAlgorithm:  HashAlgorithmArgon2id,
+		Argon2:         DefaultArgon2Params(),
+		BcryptCost:     bcrypt.DefaultCost,
+	}
+}
+
+// PasswordPolicyFromConfig builds a password policy from service configuration,
+// reading the optional deny-list file (one password per line).
+func PasswordPolicyFromConfig(cfg config.PasswordPolicyConfig) (PasswordPolicy, error) {
+	p := PasswordPolicy{
+		Version:        cfg.PolicyVersion,
+		MinLength:      cfg.MinLength,
+		RequireUpper:   cfg.RequireUpper,
+		RequireLower:   cfg.RequireLower,
+		RequireNumber:  cfg.RequireNumber,
+		RequireSpecial: cfg.RequireSpecial,
+		DenyList:       make(map[string]struct{}),
+		HashAlgorithm:  strings.ToLower(cfg.HashAlgorithm),
+		Argon2:         DefaultArgon2Params(),
+		BcryptCost:     cfg.BcryptCost,
+	}
+	if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+		p.BcryptCost = bcrypt.DefaultCost
+	}
+	switch p.HashAlgorithm {
+	case "":
+		p.HashAlgorithm = HashAlgorithmArgon2id
+	case HashAlgorithmBcrypt, HashAlgorithmArgon2id:
+	default:
+		return p, fmt.Errorf("unsupported password hash algorithm %q", cfg.HashAlgorithm)
+	}
+	if cfg.Argon2Memory > 0 {
+		p.Argon2.Memory = cfg.Argon2Memory
+	}
+	if cfg.Argon2Iterations > 0 {
+		p.Argon2.Iterations = cfg.Argon2Iterations
+	}
+	if cfg.Argon2Parallelism > 0 {
+		p.Argon2.Parallelism = cfg.Argon2Parallelism
+	}
+	for _, pw := range cfg.DenyList {
+		p.deny(pw)
+	}
+	if cfg.DenyListFile != "" {
+		f, err := os.Open(cfg.DenyListFile)
+		if err != nil {
+			return p, fmt.Errorf("failed to open password deny list: %w", err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			p.deny(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return p, fmt.Errorf("failed to read password deny list: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *PasswordPolicy) deny(password string) {
+	password = strings.ToLower(strings.TrimSpace(password))
+	if password == "" {
+		return
+	}
+	if p.DenyList == nil {
+		p.DenyList = make(map[string]struct{})
+	}
+	p.DenyList[password] = struct{}{}
+}
+
+// Validate checks a password against the policy rules.
+func (p PasswordPolicy) Validate(password string) error {
+	if len(password) < p.MinLength {
+		return fmt.Errorf("must be at least %d characters long", p.MinLength)
+	}
+	// bcrypt ignores everything past 72 bytes.
+	if len(password) > 72 {
+		return errors.New("must be at most 72 characters long")
+	}
+
+	var hasUpper, hasLower, hasNumber, hasSpecial bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsNumber(char):
+			hasNumber = true
+		case unicode.IsPunct(char) || unicode.IsSymbol(char):
+			hasSpecial = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		return errors.New("must contain at least one uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		return errors.New("must contain at least one lowercase letter")
+	}
+	if p.RequireNumber && !hasNumber {
+		return errors.New("must contain at least one number")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		return errors.New("must contain at least one special character")
+	}
+	if _, denied := p.DenyList[strings.ToLower(password)]; denied {
+		return errors.New("is too common, choose a different password")
+	}
+	return nil
+}
+
+// BreachChecker reports whether a password appears in a known breach.
+type BreachChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+// RangeBreachChecker queries a HaveIBeenPwned-compatible range API. Only the
+// first five hex characters of the password's SHA-1 are sent (k-anonymity).
+type RangeBreachChecker struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewRangeBreachChecker(baseURL string, timeout time.Duration) *RangeBreachChecker {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &RangeBreachChecker{baseURL: baseURL, client: &http.Client{Timeout: timeout}}
+}
+
+func (c *RangeBreachChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides the real number of matches from observers of the response size.
+	req.Header.Set("Add-Padding", "true")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("breach check returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		hashSuffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		// Padding entries have a count of zero.
+		if ok && strings.EqualFold(hashSuffix, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// WithPasswordPolicy replaces the default password policy.
+func (s *Service) WithPasswordPolicy(p PasswordPolicy) *Service {
+	s.passwordPolicy = p
+	return s
+}
+
+// WithBreachChecker rejects new passwords that appear in a breach corpus.
+func (s *Service) WithBreachChecker(c BreachChecker) *Service {
+	s.breachChecker = c
+	return s
+}
+
+// checkPassword applies the policy and, if configured, the breach check. The
+// breach check fails open so that an outage of the lookup service does not
+// block registration or password resets.
+func (s *Service) checkPassword(ctx context.Context, password string) error {
+	if err := s.passwordPolicy.Validate(password); err != nil {
+		return err
+	}
+	if s.breachChecker != nil {
+		if breached, err := s.breachChecker.IsBreached(ctx, password); err == nil && breached {
+			return ErrPasswordBreached
+		}
+	}
+	return nil
+}
+
+// setPassword checks and hashes a new password and stamps the policy version.
+func (s *Service) setPassword(ctx context.Context, user *domain.User, password string) error {
+	if err := s.checkPassword(ctx, password); err != nil {
+		return fmt.Errorf("invalid password: %w", err)
+	}
+	hash, err := s.passwordPolicy.hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = hash
+	user.PasswordVersion = s.passwordPolicy.Version
+	return nil
+}
+
+// upgradePassword runs after a successful login while the plaintext is at
+// hand. Passwords stored under an older policy version are re-checked: if
+// they still pass they are rehashed and stamped with the current version,
+// otherwise the caller must force a password change. Hashes made with an
+// older algorithm or weaker parameters (needsRehash) are transparently
+// replaced. It reports whether the user record changed and whether a
+// password change is required.
+func (s *Service) upgradePassword(ctx context.Context, user *domain.User, password string, needsRehash bool) (changed, changeRequired bool) {
+	p := s.passwordPolicy
+	stale := user.PasswordVersion < p.Version
+	if !stale && !needsRehash {
+		return false, false
+	}
+	if stale {
+		if err := s.checkPassword(ctx, password); err != nil {
+			return false, true
+		}
+	}
+	hash, err := p.hashPassword(password)
+	if err != nil {
+		return false, false
+	}
+	user.PasswordHash = hash
+	user.PasswordVersion = p.Version
+	return true, false
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type stubBreachChecker struct {
+	breached map[string]bool
+	err      error
+}
+
+func (c *stubBreachChecker) IsBreached(_ context.Context, password string) (bool, error) {
+	return c.breached[password], c.err
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	p, err := PasswordPolicyFromConfig(config.PasswordPolicyConfig{
+		PolicyVersion: 2,
+		MinLength:     12,
+		RequireUpper:  true,
+		RequireNumber: true,
+		DenyList:      []string{"Welcome2024Kyd"},
+		BcryptCost:    99,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, p.BcryptCost)
+
+	assert.Error(t, p.Validate("Short1"))
+	assert.Error(t, p.Validate("nouppercase123"))
+	assert.Error(t, p.Validate("welcome2024kyd"), "deny list is case-insensitive")
+	assert.NoError(t, p.Validate("NoSymbolsNeeded1"))
+}
+
+func TestRangeBreachChecker(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	var gotPath, gotPadding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotPadding = r.Header.Get("Add-Padding")
+		fmt.Fprint(w, "0018A45C4D1DEF81644B54AB7F969B88D65:0\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\r\n")
+	}))
+	defer srv.Close()
+
+	c := NewRangeBreachChecker(srv.URL, time.Second)
+	breached, err := c.IsBreached(context.Background(), "password")
+	assert.NoError(t, err)
+	assert.True(t, breached)
+	assert.Equal(t, "/5BAA6", gotPath)
+	assert.Equal(t, "true", gotPadding)
+
+	breached, err = c.IsBreached(context.Background(), "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.False(t, breached)
+}
+
+func TestCheckPassword_BreachFailsOpen(t *testing.T) {
+	service := NewService(new(MockRepository), nil, "secret", time.Hour).
+		WithBreachChecker(&stubBreachChecker{breached: map[string]bool{"Password123!": true}})
+	assert.Equal(t, ErrPasswordBreached, service.checkPassword(context.Background(), "Password123!"))
+	assert.NoError(t, service.checkPassword(context.Background(), "Unbreached123!"))
+
+	service.WithBreachChecker(&stubBreachChecker{breached: map[string]bool{"Password123!": true}, err: errors.New("timeout")})
+	assert.NoError(t, service.checkPassword(context.Background(), "Password123!"))
+}
+
+func TestLogin_UpgradesPasswordOnPolicyChange(t *testing.T) {
+	ctx := context.Background()
+	policy := testPasswordPolicy()
+	policy.Version = 2
+
+	login := func(password string, version int) (*TokenResponse, *domain.User) {
+		repo := new(MockRepository)
+		service := NewService(repo, nil, "secret", time.Hour).WithPasswordPolicy(policy)
+		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		user := &domain.User{ID: uuid.New(), Email: "user@example.com", IsActive: true, PasswordHash: string(hash), PasswordVersion: version}
+		repo.On("FindByEmail", ctx, user.Email).Return(user, nil)
+		repo.On("Update", ctx, mock.Anything).Return(nil)
+
+		resp, err := service.Login(ctx, &LoginRequest{Email: user.Email, Password: password})
+		assert.NoError(t, err)
+		return resp, user
+	}
+
+	// A compliant password is rehashed with Argon2id and stamped.
+	resp, user := login("Compliant123!", 1)
+	assert.False(t, resp.PasswordChangeRequired)
+	assert.Equal(t, 2, user.PasswordVersion)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+
+	// A password that fails the new policy still logs in but must be changed.
+	resp, user = login("weakpassword", 1)
+	assert.True(t, resp.PasswordChangeRequired)
+	assert.Equal(t, 1, user.PasswordVersion)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/sms"
+
+	"github.com/google/uuid"
+)
+
+const phoneCodeDigits = 6
+
+// PhoneVerificationRepository stores the SMS codes sent to verify phone numbers.
+type PhoneVerificationRepository interface {
+	CreatePhoneCode(ctx context.Context, c *domain.PhoneVerificationCode) error
+	// LatestPhoneCode returns nil when no code has been issued.
+	LatestPhoneCode(ctx context.Context, userID uuid.UUID) (*domain.PhoneVerificationCode, error)
+	CountPhoneCodesSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
+	IncrementPhoneCodeAttempts(ctx context.Context, id uuid.UUID) error
+	ConsumePhoneCode(ctx context.Context, id uuid.UUID) error
+}
+
+// WithPhoneVerification enables SMS one-time codes for phone numbers. Codes
+// are sent at registration and whenever the number changes.
+func (s *Service) WithPhoneVerification(sender sms.Sender, repo PhoneVerificationRepository, expiry time.Duration, maxAttempts int) *Service {
+	s.sms = sender
+	s.phoneCodes = repo
+	s.phoneCodeExpiry = expiry
+	s.phoneCodeMaxAttempts = maxAttempts
+	return s
+}
+
+// SendPhoneVerification sends a fresh code to the user's current phone number,
+// subject to the same resend limits as verification emails.
+func (s *Service) SendPhoneVerification(ctx context.Context, userID uuid.UUID) error {
+	if s.sms == nil || s.phoneCodes == nil {
+		return nil
+	}
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.PhoneVerified || user.Phone == "" {
+		return nil
+	}
+	return s.sendPhoneCode(ctx, user)
+}
+
+func (s *Service) sendPhoneCode(ctx context.Context, user *domain.User) error {
+	now := time.Now()
+	p := s.verificationPolicy
+
+	if p.ResendCooldown > 0 {
+		latest, err := s.phoneCodes.LatestPhoneCode(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if latest != nil && now.Sub(latest.CreatedAt) < p.ResendCooldown {
+			return kyderrors.ErrVerificationThrottled
+		}
+	}
+	if p.MaxResendsPerDay > 0 {
+		sent, err := s.phoneCodes.CountPhoneCodesSince(ctx, user.ID, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if sent >= p.MaxResendsPerDay {
+			return kyderrors.ErrVerificationThrottled
+		}
+	}
+
+	code, err := generatePhoneCode()
+	if err != nil {
+		return err
+	}
+	c := &domain.PhoneVerificationCode{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		CodeHash:  hashPhoneCode(user.ID, user.Phone, code),
+		ExpiresAt: now.Add(s.phoneCodeExpiry),
+		CreatedAt: now,
+	}
+	if err := s.phoneCodes.CreatePhoneCode(ctx, c); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Your KYD verification code is %s. It expires in %d minutes. Never share this code.",
+		code, int(s.phoneCodeExpiry.Minutes()))
+	return s.sms.Send(ctx, user.Phone, msg)
+}
+
+// VerifyPhone checks a code against the latest one sent to the user's current
+// number. Each wrong guess counts towards the attempt limit, after which a new
+// code must be requested.
+func (s *Service) VerifyPhone(ctx context.Context, userID uuid.UUID, code string) error {
+	if s.phoneCodes == nil {
+		return kyderrors.ErrInvalidVerificationCode
+	}
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.PhoneVerified {
+		return nil
+	}
+	latest, err := s.phoneCodes.LatestPhoneCode(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if latest == nil || latest.ConsumedAt != nil || time.Now().After(latest.ExpiresAt) {
+		return kyderrors.ErrInvalidVerificationCode
+	}
+	if s.phoneCodeMaxAttempts > 0 && latest.Attempts >= s.phoneCodeMaxAttempts {
+		return kyderrors.ErrTooManyCodeAttempts
+	}
+	expected := hashPhoneCode(userID, user.Phone, code)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(latest.CodeHash)) != 1 {
+		if err := s.phoneCodes.IncrementPhoneCodeAttempts(ctx, latest.ID); err != nil {
+			return err
+		}
+		return kyderrors.ErrInvalidVerificationCode
+	}
+	if err := s.phoneCodes.ConsumePhoneCode(ctx, latest.ID); err != nil {
+		return err
+	}
+	return s.repo.SetPhoneVerified(ctx, userID)
+}
+
+// ResetPhoneVerification clears the verified flag when a user's number
+// changes. It reports whether the number changed.
+func ResetPhoneVerification(user *domain.User, previousPhone string) bool {
+	if user.Phone == previousPhone {
+		return false
+	}
+	user.PhoneVerified = false
+	user.PhoneVerifiedAt = nil
+	return true
+}
+
+func generatePhoneCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", phoneCodeDigits, n.Int64()), nil
+}
+
+// hashPhoneCode binds a code to the user and the number it was sent to.
+func hashPhoneCode(userID uuid.UUID, phone, code string) string {
+	sum := sha256.Sum256([]byte(userID.String() + "|" + phone + "|" + code))
+	return hex.EncodeToString(sum[:])
+}
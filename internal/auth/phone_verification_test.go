@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryPhoneCodeRepository struct {
+	codes []*domain.PhoneVerificationCode
+}
+
+func (r *memoryPhoneCodeRepository) CreatePhoneCode(_ context.Context, c *domain.PhoneVerificationCode) error {
+	cp := *c
+	r.codes = append(r.codes, &cp)
+	return nil
+}
+
+func (r *memoryPhoneCodeRepository) LatestPhoneCode(_ context.Context, userID uuid.UUID) (*domain.PhoneVerificationCode, error) {
+	var latest *domain.PhoneVerificationCode
+	for _, c := range r.codes {
+		if c.UserID == userID {
+			latest = c
+		}
+	}
+	return latest, nil
+}
+
+func (r *memoryPhoneCodeRepository) CountPhoneCodesSince(_ context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	n := 0
+	for _, c := range r.codes {
+		if c.UserID == userID && !c.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryPhoneCodeRepository) IncrementPhoneCodeAttempts(_ context.Context, id uuid.UUID) error {
+	for _, c := range r.codes {
+		if c.ID == id {
+			c.Attempts++
+		}
+	}
+	return nil
+}
+
+func (r *memoryPhoneCodeRepository) ConsumePhoneCode(_ context.Context, id uuid.UUID) error {
+	for _, c := range r.codes {
+		if c.ID == id {
+			now := time.Now()
+			c.ConsumedAt = &now
+		}
+	}
+	return nil
+}
+
+type recordingSMSSender struct {
+	sent []string
+}
+
+func (s *recordingSMSSender) Send(_ context.Context, _, message string) error {
+	s.sent = append(s.sent, message)
+	return nil
+}
+
+var smsCode = regexp.MustCompile(`code is (\d{6})`)
+
+func (s *recordingSMSSender) lastCode() string {
+	return smsCode.FindStringSubmatch(s.sent[len(s.sent)-1])[1]
+}
+
+func TestVerifyPhone_AttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	codes := &memoryPhoneCodeRepository{}
+	sender := &recordingSMSSender{}
+	service := NewService(repo, nil, "secret", time.Hour).
+		WithPhoneVerification(sender, codes, 10*time.Minute, 3)
+
+	user := &domain.User{ID: uuid.New(), Phone: "+265991234567"}
+	repo.On("FindByID", ctx, user.ID).Return(user, nil)
+
+	assert.NoError(t, service.SendPhoneVerification(ctx, user.ID))
+	assert.Len(t, sender.sent, 1)
+	code := sender.lastCode()
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, kyderrors.ErrInvalidVerificationCode, service.VerifyPhone(ctx, user.ID, "abcdef"))
+	}
+	// The right code no longer helps once the attempts are used up.
+	assert.Equal(t, kyderrors.ErrTooManyCodeAttempts, service.VerifyPhone(ctx, user.ID, code))
+
+	codes.codes[0].CreatedAt = time.Now().Add(-time.Hour)
+	assert.NoError(t, service.SendPhoneVerification(ctx, user.ID))
+	repo.On("SetPhoneVerified", ctx, user.ID).Return(nil).Once()
+	assert.NoError(t, service.VerifyPhone(ctx, user.ID, sender.lastCode()))
+	assert.NotNil(t, codes.codes[1].ConsumedAt)
+	repo.AssertExpectations(t)
+}
+
+func TestVerifyPhone_CodeBoundToNumber(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	codes := &memoryPhoneCodeRepository{}
+	sender := &recordingSMSSender{}
+	service := NewService(repo, nil, "secret", time.Hour).
+		WithPhoneVerification(sender, codes, 10*time.Minute, 5).
+		WithVerificationPolicy(VerificationPolicy{ResendCooldown: time.Minute})
+
+	user := &domain.User{ID: uuid.New(), Phone: "+265991234567"}
+	repo.On("FindByID", ctx, user.ID).Return(user, nil)
+
+	assert.NoError(t, service.SendPhoneVerification(ctx, user.ID))
+	assert.Equal(t, kyderrors.ErrVerificationThrottled, service.SendPhoneVerification(ctx, user.ID))
+	code := sender.lastCode()
+
+	// Switching numbers invalidates the code sent to the old one.
+	user.Phone = "+265881234567"
+	assert.True(t, ResetPhoneVerification(user, "+265991234567"))
+	assert.Equal(t, kyderrors.ErrInvalidVerificationCode, service.VerifyPhone(ctx, user.ID, code))
+
+	codes.codes[0].ExpiresAt = time.Now().Add(-time.Second)
+	codes.codes[0].CreatedAt = time.Now().Add(-time.Hour)
+	assert.NoError(t, service.SendPhoneVerification(ctx, user.ID))
+	codes.codes[1].ExpiresAt = time.Now().Add(-time.Second)
+	assert.Equal(t, kyderrors.ErrInvalidVerificationCode, service.VerifyPhone(ctx, user.ID, sender.lastCode()))
+}
+
+func TestVerificationGate_CheckPhoneVerified(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	gate := NewVerificationGate(repo, VerificationPolicy{})
+
+	now := time.Now()
+	verified := &domain.User{ID: uuid.New(), Phone: "+265991234567", PhoneVerified: true, PhoneVerifiedAt: &now}
+	unverified := &domain.User{ID: uuid.New(), Phone: "+265881234567"}
+	repo.On("FindByID", ctx, verified.ID).Return(verified, nil)
+	repo.On("FindByID", ctx, unverified.ID).Return(unverified, nil)
+
+	assert.NoError(t, gate.CheckPhoneVerified(ctx, verified.ID))
+	assert.Equal(t, kyderrors.ErrPhoneNotVerified, gate.CheckPhoneVerified(ctx, unverified.ID))
+}
@@ -15,19 +15,17 @@ import (
 	"fmt"
 	"strings"
 	"time"
-	"unicode"
 
 	"kyd/internal/domain"
 	kyderrors "kyd/pkg/errors"
 	"kyd/pkg/mailer"
+	"kyd/pkg/sms"
 	"kyd/pkg/validator"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
-	"github.com/pquerna/otp/totp"
 	"github.com/shopspring/decimal"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // TokenBlacklist defines the interface for managing revoked tokens.
@@ -38,28 +36,48 @@ type TokenBlacklist interface {
 
 // Service provides user registration, login, and token issuance.
 type Service struct {
-	repo                Repository
-	blacklist           TokenBlacklist
-	jwtSecret           string
-	jwtSecrets          []string
-	jwtExpiry           time.Duration
-	mailer              *mailer.Mailer
-	verificationBaseURL string
-	verificationExpiry  time.Duration
-	resetBaseURL        string
-	resetExpiry         time.Duration
-	bypassVerification  bool
-	GoogleOAuth         *GoogleOAuthService // Google OAuth service
+	repo                 Repository
+	blacklist            TokenBlacklist
+	jwtSecret            string
+	jwtSecrets           []string
+	jwtExpiry            time.Duration
+	mailer               *mailer.Mailer
+	verificationBaseURL  string
+	verificationExpiry   time.Duration
+	resetBaseURL         string
+	resetExpiry          time.Duration
+	invitationBaseURL    string
+	invitationExpiry     time.Duration
+	bypassVerification   bool
+	verificationPolicy   VerificationPolicy
+	verifications        VerificationRepository
+	sms                  sms.Sender
+	phoneCodes           PhoneVerificationRepository
+	phoneCodeExpiry      time.Duration
+	phoneCodeMaxAttempts int
+	passwordPolicy       PasswordPolicy
+	breachChecker        BreachChecker
+	loginSecurity        LoginSecurityRepository
+	stepUpAudit          StepUpAuditRepository
+	stepUpLockout        StepUpLockout
+	mfa                  MFASettings
+	mfaStore             MFARepository
+	devices              DeviceRepository
+	eligibility          EligibilityPolicy
+	eligibilityRules     EligibilityRepository
+	appealHooks          []AppealHook
+	GoogleOAuth          *GoogleOAuthService // Google OAuth service
 }
 
 // NewService constructs a Service with the given repository and JWT settings.
 func NewService(repo Repository, blacklist TokenBlacklist, jwtSecret string, jwtExpiry time.Duration) *Service {
 	s := &Service{
-		repo:        repo,
-		blacklist:   blacklist,
-		jwtSecret:   jwtSecret,
-		jwtExpiry:   jwtExpiry,
-		resetExpiry: 1 * time.Hour, // Default 1 hour
+		repo:           repo,
+		blacklist:      blacklist,
+		jwtSecret:      jwtSecret,
+		jwtExpiry:      jwtExpiry,
+		resetExpiry:    1 * time.Hour, // Default 1 hour
+		passwordPolicy: DefaultPasswordPolicy(),
 	}
 	if jwtSecret != "" {
 		s.jwtSecrets = []string{jwtSecret}
@@ -120,6 +138,10 @@ type RegisterRequest struct {
 	UserType     domain.UserType `json:"user_type" validate:"required"`
 	CountryCode  string          `json:"country_code" validate:"required,len=2"`
 	BusinessName string          `json:"business_name"`
+	// Declared for the country's eligibility rules.
+	DateOfBirth  string `json:"date_of_birth" validate:"omitempty,datetime=2006-01-02"`
+	Nationality  string `json:"nationality" validate:"omitempty,len=2"`
+	BusinessType string `json:"business_type" validate:"omitempty,max=50"`
 }
 
 // LoginRequest captures credentials for login.
@@ -139,6 +161,13 @@ type TokenResponse struct {
 	RefreshToken string       `json:"refresh_token"`
 	ExpiresAt    time.Time    `json:"expires_at"`
 	User         *domain.User `json:"user"`
+	// PasswordChangeRequired is set when the password no longer satisfies the
+	// current password policy and the client must prompt for a new one.
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+	// MFA is set, and no tokens issued, when login needs a second factor.
+	MFA *MFAChallenge `json:"-"`
+	// Device is the device signed in from, when the client named one.
+	Device *domain.UserDevice `json:"device,omitempty"`
 }
 
 // Register creates a new user and returns tokens.
@@ -152,15 +181,16 @@ func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*TokenRes
 		return nil, kyderrors.ErrUserAlreadyExists
 	}
 
-	// Validate password complexity
-	if err := validatePassword(req.Password); err != nil {
-		return nil, fmt.Errorf("invalid password: %w", err)
+	var dob *time.Time
+	if req.DateOfBirth != "" {
+		t, err := time.Parse("2006-01-02", req.DateOfBirth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date_of_birth: %w", err)
+		}
+		dob = &t
 	}
-
-	// Hash password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+	if err := s.checkEligibility(ctx, req, dob); err != nil {
+		return nil, err
 	}
 
 	// Create user
@@ -168,13 +198,13 @@ func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*TokenRes
 		ID:            uuid.New(),
 		Email:         req.Email,
 		Phone:         req.Phone,
-		PasswordHash:  string(passwordHash),
 		FirstName:     req.FirstName,
 		LastName:      req.LastName,
 		UserType:      req.UserType,
 		KYCLevel:      0,
 		KYCStatus:     domain.KYCStatusPending,
 		CountryCode:   req.CountryCode,
+		DateOfBirth:   dob,
 		RiskScore:     decimal.Zero,
 		IsActive:      true,
 		EmailVerified: s.bypassVerification,
@@ -182,6 +212,11 @@ func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*TokenRes
 		UpdatedAt:     time.Now(),
 	}
 
+	// Validate password against the policy and hash it
+	if err := s.setPassword(ctx, user, req.Password); err != nil {
+		return nil, err
+	}
+
 	if req.BusinessName != "" {
 		user.BusinessName = &req.BusinessName
 	}
@@ -197,7 +232,12 @@ func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*TokenRes
 
 	// Send email verification if configured
 	if s.mailer != nil && s.verificationBaseURL != "" && !s.bypassVerification {
-		_ = s.sendVerificationEmail(user)
+		_ = s.sendVerificationEmail(ctx, user)
+	}
+
+	// Send a phone verification code if configured
+	if s.sms != nil && s.phoneCodes != nil && user.Phone != "" {
+		_ = s.sendPhoneCode(ctx, user)
 	}
 
 	// Generate tokens
@@ -216,20 +256,40 @@ func (s *Service) Login(ctx context.Context, req *LoginRequest) (*TokenResponse,
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	ok, needsRehash := s.passwordPolicy.verifyPassword(user.PasswordHash, req.Password)
+	if !ok {
 		return nil, kyderrors.ErrInvalidCredentials
 	}
 
-	// Verify TOTP if enabled
-	if user.IsTOTPEnabled {
-		if req.TOTPCode == "" {
-			return nil, kyderrors.ErrTOTPRequired
+	// Rehash or flag passwords stored under an older policy. This happens
+	// before the second factor, whose step no longer has the password.
+	_, changeRequired := s.upgradePassword(ctx, user, req.Password, needsRehash)
+
+	// Second factor: TOTP users may send their code with the password,
+	// everyone else gets a challenge to finish with VerifyMFA.
+	required, err := s.mfaRequired(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if required {
+		if req.TOTPCode == "" || !user.IsTOTPEnabled {
+			return s.mfaChallenge(ctx, user, changeRequired)
 		}
-		if user.TOTPSecret == nil || !totp.Validate(req.TOTPCode, *user.TOTPSecret) {
-			return nil, kyderrors.ErrInvalidTOTP
+		if err := s.verifySecondFactor(ctx, user, domain.MFAMethodTOTP, req.TOTPCode); err != nil {
+			return nil, err
 		}
 	}
 
+	return s.completeLogin(ctx, user, req, changeRequired, required)
+}
+
+// completeLogin records a login that passed every factor and issues tokens.
+// secondFactor is set when the user proved one, which trusts the device.
+func (s *Service) completeLogin(ctx context.Context, user *domain.User, req *LoginRequest, changeRequired, secondFactor bool) (*TokenResponse, error) {
+	// Only users who have signed in before get new-device alerts; the first
+	// login after registration is always from an unseen device.
+	returning := user.LastLogin != nil
+
 	// Update last login
 	now := time.Now()
 	user.LastLogin = &now
@@ -238,22 +298,40 @@ func (s *Service) Login(ctx context.Context, req *LoginRequest) (*TokenResponse,
 	}
 
 	// Record Device
+	var device *domain.UserDevice
 	if req.DeviceID != "" {
-		device := &domain.UserDevice{
+		if returning && !user.LoginAlertsDisabled {
+			if known, err := s.repo.HasDevice(ctx, user.ID, req.DeviceID); err == nil && !known {
+				s.sendNewDeviceAlert(user, req, now)
+			}
+		}
+		device = &domain.UserDevice{
 			UserID:      user.ID,
 			DeviceHash:  req.DeviceID,
 			DeviceName:  &req.DeviceName,
 			IPAddress:   &req.IPAddress,
 			CountryCode: &req.CountryCode,
-			IsTrusted:   true, // Trust on successful login
+			IsTrusted:   s.trustOnLogin(ctx, user, returning, secondFactor),
 			LastSeenAt:  now,
 			CreatedAt:   now,
 		}
 		// Best effort device tracking
-		_ = s.repo.AddDevice(ctx, device)
+		if err := s.repo.AddDevice(ctx, device); err != nil {
+			device = nil
+		}
 	}
 
-	return s.generateTokens(user)
+	deviceID := uuid.Nil
+	if device != nil {
+		deviceID = device.ID
+	}
+	resp, err := s.generateDeviceTokens(user, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	resp.PasswordChangeRequired = changeRequired
+	resp.Device = device
+	return resp, nil
 }
 
 // Logout invalidates the user's token by adding it to the blacklist.
@@ -295,6 +373,12 @@ func (s *Service) Logout(ctx context.Context, tokenString string) error {
 }
 
 func (s *Service) generateTokens(user *domain.User) (*TokenResponse, error) {
+	return s.generateDeviceTokens(user, uuid.Nil)
+}
+
+// generateDeviceTokens issues tokens for a sign-in on a recorded device, so
+// that revoking the device revokes them. deviceID is uuid.Nil otherwise.
+func (s *Service) generateDeviceTokens(user *domain.User, deviceID uuid.UUID) (*TokenResponse, error) {
 	expiresAt := time.Now().Add(s.jwtExpiry)
 
 	// Create access token
@@ -305,13 +389,15 @@ func (s *Service) generateTokens(user *domain.User) (*TokenResponse, error) {
 		"exp":       expiresAt.Unix(),
 		"iat":       time.Now().Unix(),
 	}
+	if user.AdminRole != "" {
+		claims["admin_role"] = string(user.AdminRole)
+	}
+	if deviceID != uuid.Nil {
+		claims["device_id"] = deviceID.String()
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signingSecret := s.jwtSecret
-	if signingSecret == "" && len(s.jwtSecrets) > 0 {
-		signingSecret = s.jwtSecrets[0]
-	}
-	accessToken, err := token.SignedString([]byte(signingSecret))
+	accessToken, err := token.SignedString([]byte(s.signingSecret()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -330,6 +416,14 @@ func (s *Service) generateTokens(user *domain.User) (*TokenResponse, error) {
 	}, nil
 }
 
+// signingSecret is the secret new tokens are signed with.
+func (s *Service) signingSecret() string {
+	if s.jwtSecret == "" && len(s.jwtSecrets) > 0 {
+		return s.jwtSecrets[0]
+	}
+	return s.jwtSecret
+}
+
 // UpdateUser updates user details.
 func (s *Service) UpdateUser(ctx context.Context, user *domain.User) error {
 	return s.repo.Update(ctx, user)
@@ -369,16 +463,11 @@ func (s *Service) ListUsersAdmin(ctx context.Context, limit, offset int, userTyp
 	return users, total, nil
 }
 
-// ChangePassword updates a user's password hash after validating complexity.
+// ChangePassword updates a user's password hash after checking the password policy.
 func (s *Service) ChangePassword(ctx context.Context, user *domain.User, newPassword string) error {
-	if err := validatePassword(newPassword); err != nil {
-		return fmt.Errorf("invalid password: %w", err)
-	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
 	}
-	user.PasswordHash = string(hash)
 	now := time.Now()
 	user.UpdatedAt = now
 	return s.repo.Update(ctx, user)
@@ -412,7 +501,11 @@ func SanitizeUserInput(u *domain.User) {
 	u.CountryCode = strings.ToUpper(strings.TrimSpace(u.CountryCode))
 }
 
-func (s *Service) sendVerificationEmail(user *domain.User) error {
+func (s *Service) sendVerificationEmail(ctx context.Context, user *domain.User) error {
+	tokenID, err := s.issueVerificationToken(ctx, user.ID)
+	if err != nil {
+		return err
+	}
 	claims := jwt.MapClaims{
 		"user_id": user.ID.String(),
 		"email":   user.Email,
@@ -420,6 +513,9 @@ func (s *Service) sendVerificationEmail(user *domain.User) error {
 		"exp":     time.Now().Add(s.verificationExpiry).Unix(),
 		"iat":     time.Now().Unix(),
 	}
+	if tokenID != "" {
+		claims["jti"] = tokenID
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signingSecret := s.jwtSecret
 	if signingSecret == "" && len(s.jwtSecrets) > 0 {
@@ -440,7 +536,7 @@ func (s *Service) sendVerificationEmail(user *domain.User) error {
 <p>Verify your email by clicking the link below:</p>
 <p><a href="%s">%s</a></p>
 <p>If you did not request this, please ignore.</p>`, user.FirstName, link, link)
-	return s.mailer.Send(user.Email, "Verify your email", body)
+	return s.mailer.SendKind(ctx, mailer.KindVerification, user.Email, "Verify your email", body)
 }
 
 func (s *Service) SendVerificationByEmail(ctx context.Context, email string) error {
@@ -451,7 +547,10 @@ func (s *Service) SendVerificationByEmail(ctx context.Context, email string) err
 	if err != nil {
 		return err
 	}
-	return s.sendVerificationEmail(user)
+	if user.EmailVerified {
+		return nil
+	}
+	return s.sendVerificationEmail(ctx, user)
 }
 
 // VerifyEmail decodes the verification token and marks the user's email as verified.
@@ -490,9 +589,22 @@ func (s *Service) VerifyEmail(ctx context.Context, tokenString string) error {
 	if err != nil {
 		return kyderrors.ErrInvalidCredentials
 	}
+	tokenID, _ := claims["jti"].(string)
+	if err := s.redeemVerificationToken(ctx, id, tokenID); err != nil {
+		return err
+	}
 	return s.repo.SetEmailVerified(ctx, id)
 }
 
+// UserIDByEmail returns the ID of the account registered with email, or nil.
+func (s *Service) UserIDByEmail(ctx context.Context, email string) *uuid.UUID {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil || user == nil {
+		return nil
+	}
+	return &user.ID
+}
+
 // DebugFindByEmail finds a user by email for debugging purposes.
 func (s *Service) DebugFindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	return s.repo.FindByEmail(ctx, email)
@@ -536,12 +648,12 @@ func (s *Service) RequestPasswordReset(ctx context.Context, email string) error
 <p>This link will expire in 1 hour. If you did not request this, please ignore this email.</p>`,
 		user.FirstName, link, link)
 
-	return s.mailer.Send(user.Email, "Reset your password", body)
+	return s.mailer.SendKind(ctx, mailer.KindPasswordReset, user.Email, "Reset your password", body)
 }
 
 // ResetPassword validates the reset token and updates the user's password.
 func (s *Service) ResetPassword(ctx context.Context, tokenString, newPassword string) error {
-	if err := validatePassword(newPassword); err != nil {
+	if err := s.passwordPolicy.Validate(newPassword); err != nil {
 		return err
 	}
 
@@ -590,11 +702,9 @@ func (s *Service) ResetPassword(ctx context.Context, tokenString, newPassword st
 	}
 
 	// Update password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
 		return err
 	}
-	user.PasswordHash = string(passwordHash)
 	user.UpdatedAt = time.Now()
 
 	return s.repo.Update(ctx, user)
@@ -608,47 +718,6 @@ func generateRandomToken(length int) (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func validatePassword(password string) error {
-	var (
-		hasUpper   bool
-		hasLower   bool
-		hasNumber  bool
-		hasSpecial bool
-	)
-
-	if len(password) < 8 {
-		return errors.New("must be at least 8 characters long")
-	}
-
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsNumber(char):
-			hasNumber = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-
-	if !hasUpper {
-		return errors.New("must contain at least one uppercase letter")
-	}
-	if !hasLower {
-		return errors.New("must contain at least one lowercase letter")
-	}
-	if !hasNumber {
-		return errors.New("must contain at least one number")
-	}
-	if !hasSpecial {
-		return errors.New("must contain at least one special character")
-	}
-
-	return nil
-}
-
 // Repository interface
 type Repository interface {
 	Create(ctx context.Context, user *domain.User) error
@@ -657,7 +726,9 @@ type Repository interface {
 	ExistsByEmail(ctx context.Context, email string) (bool, error)
 	Update(ctx context.Context, user *domain.User) error
 	SetEmailVerified(ctx context.Context, id uuid.UUID) error
+	SetPhoneVerified(ctx context.Context, id uuid.UUID) error
 	AddDevice(ctx context.Context, device *domain.UserDevice) error
+	HasDevice(ctx context.Context, userID uuid.UUID, deviceHash string) (bool, error)
 	IsCountryTrusted(ctx context.Context, userID uuid.UUID, countryCode string) (bool, error)
 	FindAll(ctx context.Context, limit, offset int, userType string) ([]*domain.User, error)
 	CountAll(ctx context.Context, userType string) (int, error)
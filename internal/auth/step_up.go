@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+)
+
+// LoginSecurityRepository records failed attempts and lockouts on the user,
+// in the same columns login security is reported from.
+type LoginSecurityRepository interface {
+	UpdateLoginSecurity(ctx context.Context, id uuid.UUID, attempts int, lockedUntil *time.Time) error
+}
+
+// StepUpAuditRepository records failed step-up attempts.
+type StepUpAuditRepository interface {
+	Create(ctx context.Context, log *domain.AuditLog) error
+}
+
+// StepUpLockout bounds guessing of step-up proofs and login codes:
+// MaxAttempts wrong ones in a row lock both for Duration.
+type StepUpLockout struct {
+	MaxAttempts int
+	Duration    time.Duration
+}
+
+// WithStepUpLockout counts wrong step-up proofs and login codes, locks them
+// once there are too many and audits every failure. Without it failures are not
+// limited.
+func (s *Service) WithStepUpLockout(repo LoginSecurityRepository, audit StepUpAuditRepository, l StepUpLockout) *Service {
+	s.loginSecurity = repo
+	s.stepUpAudit = audit
+	s.stepUpLockout = l
+	return s
+}
+
+// VerifyStepUp re-authenticates a signed-in user before a sensitive action.
+// Users with TOTP enabled prove themselves with a current code; others with
+// their password. ErrTOTPRequired is returned when TOTP is enabled and no
+// code was given, and ErrStepUpLocked while too many wrong proofs lock it.
+func (s *Service) VerifyStepUp(ctx context.Context, userID uuid.UUID, password, totpCode string) error {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return kyderrors.ErrInvalidCredentials
+	}
+	if !user.IsActive {
+		return kyderrors.ErrInvalidCredentials
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		s.auditProofFailure(ctx, user, "STEP_UP_FAILED", kyderrors.ErrStepUpLocked)
+		return kyderrors.ErrStepUpLocked
+	}
+
+	err = s.checkStepUpProof(user, password, totpCode)
+	if errors.Is(err, kyderrors.ErrTOTPRequired) {
+		// Asking for the code is not a wrong guess
+		return err
+	}
+	if err != nil {
+		return s.proofFailed(ctx, user, "STEP_UP_FAILED", err)
+	}
+	if s.loginSecurity != nil && (user.FailedLoginAttempts > 0 || user.LockedUntil != nil) {
+		_ = s.loginSecurity.UpdateLoginSecurity(ctx, user.ID, 0, nil)
+	}
+	return nil
+}
+
+func (s *Service) checkStepUpProof(user *domain.User, password, totpCode string) error {
+	if user.IsTOTPEnabled {
+		if totpCode == "" {
+			return kyderrors.ErrTOTPRequired
+		}
+		if user.TOTPSecret == nil || !totp.Validate(totpCode, *user.TOTPSecret) {
+			return kyderrors.ErrInvalidTOTP
+		}
+		return nil
+	}
+	if password == "" {
+		return kyderrors.ErrInvalidCredentials
+	}
+	if ok, _ := s.passwordPolicy.verifyPassword(user.PasswordHash, password); !ok {
+		return kyderrors.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// proofFailed counts a wrong step-up proof or login code, locking both when
+// it is one too many, and audits it under action. It returns the error to
+// give the caller.
+func (s *Service) proofFailed(ctx context.Context, user *domain.User, action string, cause error) error {
+	if s.loginSecurity == nil || s.stepUpLockout.MaxAttempts <= 0 {
+		s.auditProofFailure(ctx, user, action, cause)
+		return cause
+	}
+	attempts := user.FailedLoginAttempts + 1
+	var lockedUntil *time.Time
+	if attempts >= s.stepUpLockout.MaxAttempts {
+		until := time.Now().Add(s.stepUpLockout.Duration)
+		lockedUntil, attempts = &until, 0
+	}
+	if err := s.loginSecurity.UpdateLoginSecurity(ctx, user.ID, attempts, lockedUntil); err != nil {
+		return err
+	}
+	if lockedUntil != nil {
+		cause = kyderrors.ErrStepUpLocked
+	}
+	s.auditProofFailure(ctx, user, action, cause)
+	return cause
+}
+
+func (s *Service) auditProofFailure(ctx context.Context, user *domain.User, action string, cause error) {
+	if s.stepUpAudit == nil {
+		return
+	}
+	_ = s.stepUpAudit.Create(ctx, &domain.AuditLog{
+		ID:           uuid.New(),
+		Action:       action,
+		Resource:     "users",
+		ResourceID:   user.ID.String(),
+		UserID:       &user.ID,
+		Status:       "failure",
+		ErrorMessage: cause.Error(),
+		CreatedAt:    time.Now(),
+	})
+}
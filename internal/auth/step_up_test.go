@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyStepUp(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	policy := testPasswordPolicy()
+	service := NewService(repo, nil, "secret", time.Hour).WithPasswordPolicy(policy)
+
+	hash, err := policy.hashPassword("Correct123!")
+	require.NoError(t, err)
+	user := &domain.User{ID: uuid.New(), IsActive: true, PasswordHash: hash}
+	repo.On("FindByID", ctx, user.ID).Return(user, nil)
+
+	assert.NoError(t, service.VerifyStepUp(ctx, user.ID, "Correct123!", ""))
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "wrong", ""), kyderrors.ErrInvalidCredentials)
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "", ""), kyderrors.ErrInvalidCredentials)
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "KYD", AccountName: "user@example.com"})
+	require.NoError(t, err)
+	secret := key.Secret()
+	user.TOTPSecret = &secret
+	user.IsTOTPEnabled = true
+
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "Correct123!", ""), kyderrors.ErrTOTPRequired, "TOTP users must use a code")
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "", "abcdef"), kyderrors.ErrInvalidTOTP)
+}
+
+// memLoginSecurity writes attempts straight onto the user the mock
+// repository returns, as the database would.
+type memLoginSecurity struct{ user *domain.User }
+
+func (m *memLoginSecurity) UpdateLoginSecurity(ctx context.Context, id uuid.UUID, attempts int, lockedUntil *time.Time) error {
+	m.user.FailedLoginAttempts, m.user.LockedUntil = attempts, lockedUntil
+	return nil
+}
+
+type memAudit struct{ logs []*domain.AuditLog }
+
+func (m *memAudit) Create(ctx context.Context, log *domain.AuditLog) error {
+	m.logs = append(m.logs, log)
+	return nil
+}
+
+func TestVerifyStepUp_LocksAfterRepeatedFailures(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	policy := testPasswordPolicy()
+	hash, err := policy.hashPassword("Correct123!")
+	require.NoError(t, err)
+	user := &domain.User{ID: uuid.New(), IsActive: true, PasswordHash: hash}
+	repo.On("FindByID", ctx, user.ID).Return(user, nil)
+	audit := &memAudit{}
+	service := NewService(repo, nil, "secret", time.Hour).WithPasswordPolicy(policy).
+		WithStepUpLockout(&memLoginSecurity{user: user}, audit, StepUpLockout{MaxAttempts: 3, Duration: time.Minute})
+
+	// A success clears earlier failures.
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "wrong", ""), kyderrors.ErrInvalidCredentials)
+	require.NoError(t, service.VerifyStepUp(ctx, user.ID, "Correct123!", ""))
+	assert.Zero(t, user.FailedLoginAttempts)
+
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "wrong", ""), kyderrors.ErrInvalidCredentials)
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "wrong", ""), kyderrors.ErrInvalidCredentials)
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "wrong", ""), kyderrors.ErrStepUpLocked)
+	require.NotNil(t, user.LockedUntil)
+
+	// Even the right password is refused while locked.
+	assert.ErrorIs(t, service.VerifyStepUp(ctx, user.ID, "Correct123!", ""), kyderrors.ErrStepUpLocked)
+	assert.Len(t, audit.logs, 5)
+	for _, l := range audit.logs {
+		assert.Equal(t, "STEP_UP_FAILED", l.Action)
+		assert.Equal(t, user.ID.String(), l.ResourceID)
+	}
+
+	past := time.Now().Add(-time.Second)
+	user.LockedUntil = &past
+	require.NoError(t, service.VerifyStepUp(ctx, user.ID, "Correct123!", ""))
+	assert.Nil(t, user.LockedUntil)
+}
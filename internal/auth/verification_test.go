@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/mailer"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type memoryVerificationRepository struct {
+	tokens []*domain.EmailVerificationToken
+}
+
+func (r *memoryVerificationRepository) CreateVerificationToken(_ context.Context, t *domain.EmailVerificationToken) error {
+	cp := *t
+	r.tokens = append(r.tokens, &cp)
+	return nil
+}
+
+func (r *memoryVerificationRepository) LatestVerificationToken(_ context.Context, userID uuid.UUID) (*domain.EmailVerificationToken, error) {
+	var latest *domain.EmailVerificationToken
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			latest = t
+		}
+	}
+	return latest, nil
+}
+
+func (r *memoryVerificationRepository) CountVerificationTokensSince(_ context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	n := 0
+	for _, t := range r.tokens {
+		if t.UserID == userID && !t.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryVerificationRepository) ConsumeVerificationToken(_ context.Context, id uuid.UUID) error {
+	for _, t := range r.tokens {
+		if t.ID == id {
+			now := time.Now()
+			t.ConsumedAt = &now
+		}
+	}
+	return nil
+}
+
+func TestVerificationPolicyFromConfig(t *testing.T) {
+	p := VerificationPolicyFromConfig(config.VerificationConfig{
+		RequireVerifiedEmail: true,
+		GracePeriod:          time.Hour,
+		GracePeriods:         []string{"Acme=72h", "globex=bogus", "initech"},
+	})
+
+	assert.True(t, p.Required)
+	assert.Equal(t, 72*time.Hour, p.gracePeriodFor("acme"))
+	assert.Equal(t, 72*time.Hour, p.gracePeriodFor("ACME"))
+	assert.Equal(t, time.Hour, p.gracePeriodFor("globex"))
+	assert.Equal(t, time.Hour, p.gracePeriodFor("initech"))
+	assert.Equal(t, time.Hour, p.gracePeriodFor(""))
+}
+
+func TestVerificationGate(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	gate := NewVerificationGate(repo, VerificationPolicy{
+		Required:     true,
+		GracePeriod:  24 * time.Hour,
+		GracePeriods: map[string]time.Duration{"acme": 7 * 24 * time.Hour},
+	})
+
+	fresh := &domain.User{ID: uuid.New(), UserType: domain.UserTypeIndividual, CreatedAt: time.Now().Add(-time.Hour)}
+	stale := &domain.User{ID: uuid.New(), UserType: domain.UserTypeIndividual, Tenant: domain.DefaultTenant, CreatedAt: time.Now().Add(-48 * time.Hour)}
+	verified := &domain.User{ID: uuid.New(), UserType: domain.UserTypeIndividual, EmailVerified: true, CreatedAt: time.Now().Add(-48 * time.Hour)}
+	tenant := &domain.User{ID: uuid.New(), UserType: domain.UserTypeIndividual, Tenant: "acme", CreatedAt: time.Now().Add(-48 * time.Hour)}
+	for _, u := range []*domain.User{fresh, stale, verified, tenant} {
+		repo.On("FindByID", ctx, u.ID).Return(u, nil)
+	}
+
+	assert.NoError(t, gate.CheckEmailVerified(ctx, fresh.ID))
+	assert.Equal(t, kyderrors.ErrEmailNotVerified, gate.CheckEmailVerified(ctx, stale.ID))
+	assert.NoError(t, gate.CheckEmailVerified(ctx, verified.ID))
+	assert.NoError(t, gate.CheckEmailVerified(ctx, tenant.ID))
+
+	disabled := NewVerificationGate(repo, VerificationPolicy{Required: false})
+	assert.NoError(t, disabled.CheckEmailVerified(ctx, stale.ID))
+}
+
+var verificationLink = regexp.MustCompile(`token=([A-Za-z0-9_\-\.]+)`)
+
+func TestVerificationResendThrottleAndSingleUse(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	store := &memoryVerificationRepository{}
+	sender := new(MockSender)
+	m := &mailer.Mailer{}
+	m.WithSender(sender)
+
+	service := NewService(repo, nil, "secret", time.Hour).
+		WithEmailVerification(m, "http://verify", time.Hour, false).
+		WithVerificationPolicy(VerificationPolicy{ResendCooldown: time.Minute, MaxResendsPerDay: 2}).
+		WithVerificationStore(store)
+
+	user := &domain.User{ID: uuid.New(), Email: "new@example.com", FirstName: "New"}
+	repo.On("FindByEmail", ctx, user.Email).Return(user, nil)
+
+	var links []string
+	sender.On("Send", user.Email, "Verify your email", mock.Anything).Run(func(args mock.Arguments) {
+		links = append(links, verificationLink.FindStringSubmatch(args.String(2))[1])
+	}).Return(nil)
+
+	assert.NoError(t, service.SendVerificationByEmail(ctx, user.Email))
+	assert.Equal(t, kyderrors.ErrVerificationThrottled, service.SendVerificationByEmail(ctx, user.Email))
+
+	// Once the cooldown has passed a second link supersedes the first.
+	store.tokens[0].CreatedAt = time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, service.SendVerificationByEmail(ctx, user.Email))
+	assert.Len(t, links, 2)
+
+	// The daily cap stops further resends.
+	store.tokens[1].CreatedAt = time.Now().Add(-2 * time.Minute)
+	assert.Equal(t, kyderrors.ErrVerificationThrottled, service.SendVerificationByEmail(ctx, user.Email))
+
+	repo.On("SetEmailVerified", ctx, user.ID).Return(nil).Once()
+	assert.Equal(t, kyderrors.ErrInvalidCredentials, service.VerifyEmail(ctx, links[0]))
+	assert.NoError(t, service.VerifyEmail(ctx, links[1]))
+	assert.Equal(t, kyderrors.ErrInvalidCredentials, service.VerifyEmail(ctx, links[1]))
+	repo.AssertExpectations(t)
+}
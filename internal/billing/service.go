@@ -0,0 +1,586 @@
+// Package billing meters usage and invoices accounts each month for their
+// plan fee, metered usage and one-off charges, and collects the invoices.
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvoiceNotFound       = errors.New("invoice not found")
+	ErrInvoiceNotCollectible = errors.New("invoice is not awaiting payment")
+	ErrInvalidReference      = errors.New("payment reference is required")
+)
+
+// Repository stores usage, charges and invoices.
+type Repository interface {
+	// AddUsage adds quantity to the account's total for the metric and
+	// currency in the month starting periodStart.
+	AddUsage(ctx context.Context, userID uuid.UUID, metric domain.UsageMetric, currency domain.Currency, periodStart time.Time, quantity decimal.Decimal) error
+	ListUsage(ctx context.Context, userID uuid.UUID, periodStart time.Time) ([]domain.UsageTotal, error)
+	AddCharge(ctx context.Context, charge *domain.BillingCharge) error
+	// ListUnbilledCharges returns the account's charges created before
+	// the given time that are not on an invoice yet.
+	ListUnbilledCharges(ctx context.Context, userID uuid.UUID, before time.Time) ([]domain.BillingCharge, error)
+	// ListBillableUsers returns accounts that may owe something for the
+	// month: those on a paid plan, with usage in it or with unbilled charges.
+	ListBillableUsers(ctx context.Context, periodStart, periodEnd time.Time) ([]uuid.UUID, error)
+	// CreateInvoice stores inv with its lines and marks the charges as
+	// billed by it, atomically. It returns false, storing nothing, when the
+	// account already has an invoice for the month and currency.
+	CreateInvoice(ctx context.Context, inv *domain.Invoice, chargeIDs []uuid.UUID) (bool, error)
+	// GetInvoice returns the invoice with its lines, or nil.
+	GetInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error)
+	ListInvoices(ctx context.Context, filter InvoiceFilter) ([]domain.Invoice, error)
+	// ListDueInvoices returns collectible invoices with a wallet debit due
+	// and open external invoices past their due date.
+	ListDueInvoices(ctx context.Context, now time.Time, limit int) ([]domain.Invoice, error)
+	// UpdateInvoice saves the collection state of inv unless it has
+	// stopped being collectible meanwhile, reporting whether it did.
+	UpdateInvoice(ctx context.Context, inv *domain.Invoice) (bool, error)
+}
+
+// InvoiceFilter narrows an invoice listing. Zero fields match everything.
+type InvoiceFilter struct {
+	UserID *uuid.UUID
+	Status domain.InvoiceStatus
+	Limit  int
+	Offset int
+}
+
+// PlanSource reports and changes the plan an account is on.
+type PlanSource interface {
+	Subscription(ctx context.Context, userID uuid.UUID) (*domain.PlanSubscription, error)
+	Plan(code domain.PlanCode) (domain.Plan, error)
+	AssignPlan(ctx context.Context, userID uuid.UUID, to domain.PlanCode, adminID uuid.UUID) (*domain.PlanSubscription, error)
+}
+
+// WalletCollector debits an invoice from the account's wallet in its
+// currency. It returns pkgerrors.ErrWalletNotFound when the account has no
+// such wallet. Retries with the same invoice must not debit twice.
+type WalletCollector interface {
+	CollectInvoice(ctx context.Context, inv *domain.Invoice) (*domain.Transaction, error)
+}
+
+// Notifier tells account holders about their invoices.
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
+// Pricing sets what metered usage costs and how invoices are collected.
+type Pricing struct {
+	// Currency is what API calls are priced in.
+	Currency         domain.Currency
+	IncludedAPICalls int64
+	APICallPrice     decimal.Decimal
+	// PayoutVolumeRate is the share of payout volume charged, billed in
+	// the currency paid out.
+	PayoutVolumeRate decimal.Decimal
+	PaymentTerms     time.Duration
+	RetryInterval    time.Duration
+	MaxAttempts      int
+}
+
+// dueBatchSize bounds the invoices collected per run.
+const dueBatchSize = 200
+
+type Service struct {
+	repo      Repository
+	plans     PlanSource
+	collector WalletCollector
+	notifier  Notifier
+	pricing   Pricing
+	interval  time.Duration
+	logger    logger.Logger
+	now       func() time.Time
+
+	// billed is the last month fully invoiced by this instance, so each
+	// run after it only collects.
+	mu     sync.Mutex
+	billed time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, plans PlanSource, collector WalletCollector, pricing Pricing, log logger.Logger) *Service {
+	return &Service{
+		repo:      repo,
+		plans:     plans,
+		collector: collector,
+		pricing:   pricing,
+		interval:  time.Hour,
+		logger:    log,
+		now:       time.Now,
+		stop:      make(chan struct{}),
+	}
+}
+
+// WithNotifier tells account holders when invoices are issued, paid or fail.
+func (s *Service) WithNotifier(n Notifier) *Service {
+	s.notifier = n
+	return s
+}
+
+// WithInterval sets how often the worker runs.
+func (s *Service) WithInterval(d time.Duration) *Service {
+	if d > 0 {
+		s.interval = d
+	}
+	return s
+}
+
+// Start invoices the last month if that has not happened yet and collects
+// due invoices, now and then every interval until Stop.
+func (s *Service) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			s.Run(ctx)
+			cancel()
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Run performs one billing cycle: invoicing the previous month and
+// collecting whatever is due.
+func (s *Service) Run(ctx context.Context) {
+	lastMonth := startOfMonth(s.now()).AddDate(0, -1, 0)
+	s.mu.Lock()
+	billed := s.billed.Equal(lastMonth)
+	s.mu.Unlock()
+	if !billed {
+		n, err := s.GenerateInvoices(ctx, lastMonth)
+		if err != nil {
+			s.logger.Error("Invoice generation failed", map[string]interface{}{"period": lastMonth.Format("2006-01"), "error": err.Error()})
+		} else {
+			s.mu.Lock()
+			s.billed = lastMonth
+			s.mu.Unlock()
+			if n > 0 {
+				s.logger.Info("Invoices issued", map[string]interface{}{"period": lastMonth.Format("2006-01"), "count": n})
+			}
+		}
+	}
+	if err := s.CollectDue(ctx); err != nil {
+		s.logger.Error("Invoice collection failed", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// RecordUsage adds to the account's usage of metric this month. Currency
+// is empty for counted metrics.
+func (s *Service) RecordUsage(ctx context.Context, userID uuid.UUID, metric domain.UsageMetric, quantity decimal.Decimal, currency domain.Currency) error {
+	if !quantity.IsPositive() {
+		return nil
+	}
+	return s.repo.AddUsage(ctx, userID, metric, currency, startOfMonth(s.now()), quantity)
+}
+
+// Usage returns the account's metered usage so far this month.
+func (s *Service) Usage(ctx context.Context, userID uuid.UUID) ([]domain.UsageTotal, error) {
+	return s.repo.ListUsage(ctx, userID, startOfMonth(s.now()))
+}
+
+// PlanChanged queues the proration of a plan upgrade for the next invoice.
+func (s *Service) PlanChanged(ctx context.Context, change *domain.PlanChange) error {
+	if !change.Proration.IsPositive() {
+		return nil
+	}
+	return s.repo.AddCharge(ctx, &domain.BillingCharge{
+		ID:          uuid.New(),
+		UserID:      change.UserID,
+		Amount:      change.Proration,
+		Currency:    change.Currency,
+		Description: fmt.Sprintf("Upgrade from %s to %s, prorated", change.From, change.To),
+		CreatedAt:   s.now().UTC(),
+	})
+}
+
+// GenerateInvoices invoices every billable account for the calendar month
+// containing month and tries to collect each new invoice. Accounts already
+// invoiced for the month are skipped, so it is safe to repeat; an error
+// means some accounts were not invoiced and the month should be run again.
+func (s *Service) GenerateInvoices(ctx context.Context, month time.Time) (int, error) {
+	start := startOfMonth(month)
+	end := start.AddDate(0, 1, 0)
+	users, err := s.repo.ListBillableUsers(ctx, start, end)
+	if err != nil {
+		return 0, err
+	}
+	issued, failed := 0, 0
+	for _, userID := range users {
+		n, err := s.invoiceUser(ctx, userID, start, end)
+		issued += n
+		if err != nil {
+			failed++
+			s.logger.Error("Failed to invoice account", map[string]interface{}{"user_id": userID, "error": err.Error()})
+		}
+	}
+	if failed > 0 {
+		return issued, fmt.Errorf("%d of %d accounts not invoiced", failed, len(users))
+	}
+	return issued, nil
+}
+
+func (s *Service) invoiceUser(ctx context.Context, userID uuid.UUID, start, end time.Time) (int, error) {
+	invoices, charges, err := s.buildInvoices(ctx, userID, start, end)
+	if err != nil {
+		return 0, err
+	}
+	issued := 0
+	for _, inv := range invoices {
+		created, err := s.repo.CreateInvoice(ctx, inv, charges[inv.Currency])
+		if err != nil {
+			return issued, err
+		}
+		if !created {
+			continue
+		}
+		issued++
+		s.notify(inv, "INVOICE_ISSUED")
+		s.collect(ctx, inv)
+	}
+	return issued, nil
+}
+
+// buildInvoices prices the account's month, one invoice per currency, and
+// returns the charges each invoice bills.
+func (s *Service) buildInvoices(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]*domain.Invoice, map[domain.Currency][]uuid.UUID, error) {
+	var lines []pricedLine
+
+	sub, err := s.plans.Subscription(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := s.plans.Plan(sub.Plan)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan.MonthlyFee.IsPositive() {
+		lines = append(lines, pricedLine{plan.FeeCurrency, domain.InvoiceLine{
+			Kind:        domain.InvoiceLinePlanFee,
+			Description: plan.Name + " plan",
+			Quantity:    decimal.NewFromInt(1),
+			UnitPrice:   plan.MonthlyFee,
+			Amount:      plan.MonthlyFee,
+		}})
+	}
+
+	charges, err := s.repo.ListUnbilledCharges(ctx, userID, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	chargeIDs := make(map[domain.Currency][]uuid.UUID)
+	for _, c := range charges {
+		lines = append(lines, pricedLine{c.Currency, domain.InvoiceLine{
+			Kind:        domain.InvoiceLineCharge,
+			Description: c.Description,
+			Quantity:    decimal.NewFromInt(1),
+			UnitPrice:   c.Amount,
+			Amount:      c.Amount,
+		}})
+		chargeIDs[c.Currency] = append(chargeIDs[c.Currency], c.ID)
+	}
+
+	usage, err := s.repo.ListUsage(ctx, userID, start)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, u := range usage {
+		if line, ok := s.priceUsage(u); ok {
+			lines = append(lines, line)
+		}
+	}
+
+	now := s.now().UTC()
+	byCurrency := make(map[domain.Currency]*domain.Invoice)
+	var invoices []*domain.Invoice
+	for _, l := range lines {
+		inv, ok := byCurrency[l.currency]
+		if !ok {
+			id := uuid.New()
+			inv = &domain.Invoice{
+				ID:               id,
+				Number:           invoiceNumber(start, id),
+				UserID:           userID,
+				Currency:         l.currency,
+				PeriodStart:      start,
+				PeriodEnd:        end,
+				Total:            decimal.Zero,
+				Status:           domain.InvoiceStatusOpen,
+				CollectionMethod: domain.CollectionWallet,
+				DueAt:            now,
+				NextAttemptAt:    &now,
+				CreatedAt:        now,
+				UpdatedAt:        now,
+			}
+			byCurrency[l.currency] = inv
+			invoices = append(invoices, inv)
+		}
+		l.line.ID = uuid.New()
+		l.line.InvoiceID = inv.ID
+		inv.Lines = append(inv.Lines, l.line)
+		inv.Total = inv.Total.Add(l.line.Amount)
+	}
+	sort.Slice(invoices, func(i, j int) bool { return invoices[i].Currency < invoices[j].Currency })
+	return invoices, chargeIDs, nil
+}
+
+type pricedLine struct {
+	currency domain.Currency
+	line     domain.InvoiceLine
+}
+
+func (s *Service) priceUsage(u domain.UsageTotal) (pricedLine, bool) {
+	switch u.Metric {
+	case domain.UsageAPICalls:
+		billable := u.Quantity.Sub(decimal.NewFromInt(s.pricing.IncludedAPICalls))
+		if !billable.IsPositive() || !s.pricing.APICallPrice.IsPositive() {
+			return pricedLine{}, false
+		}
+		return pricedLine{s.pricing.Currency, domain.InvoiceLine{
+			Kind:        domain.InvoiceLineAPICalls,
+			Description: fmt.Sprintf("API calls beyond the %d included", s.pricing.IncludedAPICalls),
+			Quantity:    billable,
+			UnitPrice:   s.pricing.APICallPrice,
+			Amount:      billable.Mul(s.pricing.APICallPrice).Round(2),
+		}}, true
+	case domain.UsagePayoutVolume:
+		amount := u.Quantity.Mul(s.pricing.PayoutVolumeRate).Round(2)
+		if !amount.IsPositive() {
+			return pricedLine{}, false
+		}
+		return pricedLine{u.Currency, domain.InvoiceLine{
+			Kind:        domain.InvoiceLinePayoutVolume,
+			Description: fmt.Sprintf("Payout volume (%s)", u.Currency),
+			Quantity:    u.Quantity,
+			UnitPrice:   s.pricing.PayoutVolumeRate,
+			Amount:      amount,
+		}}, true
+	}
+	return pricedLine{}, false
+}
+
+// CollectDue retries wallet debits that are due and marks unpaid external
+// invoices past due.
+func (s *Service) CollectDue(ctx context.Context) error {
+	invoices, err := s.repo.ListDueInvoices(ctx, s.now().UTC(), dueBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range invoices {
+		inv := &invoices[i]
+		if inv.CollectionMethod == domain.CollectionExternal {
+			inv.Status = domain.InvoiceStatusPastDue
+			inv.UpdatedAt = s.now().UTC()
+			if s.save(ctx, inv) {
+				s.notify(inv, "INVOICE_PAST_DUE")
+			}
+			continue
+		}
+		s.collect(ctx, inv)
+	}
+	return nil
+}
+
+// collect debits a wallet invoice and moves it along the dunning schedule
+// when that fails. Invoices for accounts without a wallet in the currency
+// switch to external collection.
+func (s *Service) collect(ctx context.Context, inv *domain.Invoice) {
+	if inv.CollectionMethod != domain.CollectionWallet || !inv.IsCollectible() {
+		return
+	}
+	tx, err := s.collector.CollectInvoice(ctx, inv)
+	now := s.now().UTC()
+	inv.UpdatedAt = now
+	switch {
+	case err == nil:
+		s.markPaid(inv, now, tx.Reference, &tx.ID)
+		if s.save(ctx, inv) {
+			s.notify(inv, "INVOICE_PAID")
+		}
+		return
+	case errors.Is(err, pkgerrors.ErrWalletNotFound):
+		inv.CollectionMethod = domain.CollectionExternal
+		inv.DueAt = now.Add(s.pricing.PaymentTerms)
+		inv.NextAttemptAt = nil
+		s.save(ctx, inv)
+		return
+	}
+
+	inv.Attempts++
+	inv.LastError = err.Error()
+	if inv.Attempts >= s.pricing.MaxAttempts {
+		inv.Status = domain.InvoiceStatusUncollectible
+		inv.NextAttemptAt = nil
+		if s.save(ctx, inv) {
+			s.notify(inv, "INVOICE_UNCOLLECTIBLE")
+			s.downgrade(ctx, inv.UserID)
+		}
+		return
+	}
+	next := now.Add(time.Duration(inv.Attempts) * s.pricing.RetryInterval)
+	inv.Status = domain.InvoiceStatusPastDue
+	inv.NextAttemptAt = &next
+	if s.save(ctx, inv) {
+		s.notify(inv, "INVOICE_PAYMENT_FAILED")
+	}
+}
+
+// downgrade moves an account that did not pay to the free plan.
+func (s *Service) downgrade(ctx context.Context, userID uuid.UUID) {
+	sub, err := s.plans.Subscription(ctx, userID)
+	if err != nil || sub.Plan == domain.PlanConsumer {
+		return
+	}
+	if _, err := s.plans.AssignPlan(ctx, userID, domain.PlanConsumer, uuid.Nil); err != nil {
+		s.logger.Error("Failed to downgrade account after unpaid invoice", map[string]interface{}{"user_id": userID, "error": err.Error()})
+	}
+}
+
+// PayInvoice debits one of the caller's unpaid invoices from their wallet
+// now, whatever its collection method. A failed attempt here does not
+// count towards dunning.
+func (s *Service) PayInvoice(ctx context.Context, userID, invoiceID uuid.UUID) (*domain.Invoice, error) {
+	inv, err := s.GetInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if !inv.IsCollectible() {
+		return nil, ErrInvoiceNotCollectible
+	}
+	tx, err := s.collector.CollectInvoice(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	s.markPaid(inv, s.now().UTC(), tx.Reference, &tx.ID)
+	if !s.save(ctx, inv) {
+		return nil, ErrInvoiceNotCollectible
+	}
+	s.notify(inv, "INVOICE_PAID")
+	return inv, nil
+}
+
+// MarkPaidExternally records payment received outside the platform (admin).
+func (s *Service) MarkPaidExternally(ctx context.Context, invoiceID uuid.UUID, reference string, adminID uuid.UUID) (*domain.Invoice, error) {
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return nil, ErrInvalidReference
+	}
+	inv, err := s.repo.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil {
+		return nil, ErrInvoiceNotFound
+	}
+	if !inv.IsCollectible() {
+		return nil, ErrInvoiceNotCollectible
+	}
+	inv.CollectionMethod = domain.CollectionExternal
+	s.markPaid(inv, s.now().UTC(), reference, nil)
+	if !s.save(ctx, inv) {
+		return nil, ErrInvoiceNotCollectible
+	}
+	s.logger.Info("Invoice paid externally", map[string]interface{}{"invoice_id": inv.ID, "reference": reference, "admin_id": adminID})
+	s.notify(inv, "INVOICE_PAID")
+	return inv, nil
+}
+
+// GetInvoice returns one of the account's invoices with its lines.
+func (s *Service) GetInvoice(ctx context.Context, userID, invoiceID uuid.UUID) (*domain.Invoice, error) {
+	inv, err := s.repo.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.UserID != userID {
+		return nil, ErrInvoiceNotFound
+	}
+	return inv, nil
+}
+
+// ListInvoices returns invoices newest first, without their lines.
+func (s *Service) ListInvoices(ctx context.Context, filter InvoiceFilter) ([]domain.Invoice, error) {
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return s.repo.ListInvoices(ctx, filter)
+}
+
+func (s *Service) markPaid(inv *domain.Invoice, now time.Time, reference string, txID *uuid.UUID) {
+	inv.Status = domain.InvoiceStatusPaid
+	inv.PaidAt = &now
+	inv.PaymentReference = reference
+	inv.TransactionID = txID
+	inv.NextAttemptAt = nil
+	inv.LastError = ""
+	inv.UpdatedAt = now
+}
+
+// save stores inv, reporting false when another run settled it first.
+func (s *Service) save(ctx context.Context, inv *domain.Invoice) bool {
+	updated, err := s.repo.UpdateInvoice(ctx, inv)
+	if err != nil {
+		s.logger.Error("Failed to update invoice", map[string]interface{}{"invoice_id": inv.ID, "error": err.Error()})
+		return false
+	}
+	return updated
+}
+
+func (s *Service) notify(inv *domain.Invoice, event string) {
+	if s.notifier == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"invoice_id":        inv.ID.String(),
+		"number":            inv.Number,
+		"total":             inv.Total.String(),
+		"currency":          string(inv.Currency),
+		"status":            string(inv.Status),
+		"collection_method": string(inv.CollectionMethod),
+		"due_at":            inv.DueAt,
+	}
+	if inv.NextAttemptAt != nil {
+		data["next_attempt_at"] = *inv.NextAttemptAt
+	}
+	go func(userID uuid.UUID) {
+		_ = s.notifier.Notify(context.Background(), userID, event, data)
+	}(inv.UserID)
+}
+
+// invoiceNumber is unique and tells the billing month at a glance.
+func invoiceNumber(periodStart time.Time, id uuid.UUID) string {
+	return fmt.Sprintf("INV-%s-%s", periodStart.Format("200601"), strings.ToUpper(strings.ReplaceAll(id.String(), "-", "")[:8]))
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
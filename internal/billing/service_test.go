@@ -0,0 +1,405 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) AddUsage(ctx context.Context, userID uuid.UUID, metric domain.UsageMetric, currency domain.Currency, periodStart time.Time, quantity decimal.Decimal) error {
+	return m.Called(ctx, userID, metric, currency, periodStart, quantity).Error(0)
+}
+
+func (m *MockRepository) ListUsage(ctx context.Context, userID uuid.UUID, periodStart time.Time) ([]domain.UsageTotal, error) {
+	args := m.Called(ctx, userID, periodStart)
+	usage, _ := args.Get(0).([]domain.UsageTotal)
+	return usage, args.Error(1)
+}
+
+func (m *MockRepository) AddCharge(ctx context.Context, c *domain.BillingCharge) error {
+	return m.Called(ctx, c).Error(0)
+}
+
+func (m *MockRepository) ListUnbilledCharges(ctx context.Context, userID uuid.UUID, before time.Time) ([]domain.BillingCharge, error) {
+	args := m.Called(ctx, userID, before)
+	charges, _ := args.Get(0).([]domain.BillingCharge)
+	return charges, args.Error(1)
+}
+
+func (m *MockRepository) ListBillableUsers(ctx context.Context, periodStart, periodEnd time.Time) ([]uuid.UUID, error) {
+	args := m.Called(ctx, periodStart, periodEnd)
+	users, _ := args.Get(0).([]uuid.UUID)
+	return users, args.Error(1)
+}
+
+func (m *MockRepository) CreateInvoice(ctx context.Context, inv *domain.Invoice, chargeIDs []uuid.UUID) (bool, error) {
+	args := m.Called(ctx, inv, chargeIDs)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
+	args := m.Called(ctx, id)
+	inv, _ := args.Get(0).(*domain.Invoice)
+	if inv != nil {
+		cp := *inv
+		inv = &cp
+	}
+	return inv, args.Error(1)
+}
+
+func (m *MockRepository) ListInvoices(ctx context.Context, filter InvoiceFilter) ([]domain.Invoice, error) {
+	args := m.Called(ctx, filter)
+	invoices, _ := args.Get(0).([]domain.Invoice)
+	return invoices, args.Error(1)
+}
+
+func (m *MockRepository) ListDueInvoices(ctx context.Context, now time.Time, limit int) ([]domain.Invoice, error) {
+	args := m.Called(ctx, now, limit)
+	invoices, _ := args.Get(0).([]domain.Invoice)
+	return invoices, args.Error(1)
+}
+
+func (m *MockRepository) UpdateInvoice(ctx context.Context, inv *domain.Invoice) (bool, error) {
+	args := m.Called(ctx, inv)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockPlans struct {
+	mock.Mock
+}
+
+func (m *MockPlans) Subscription(ctx context.Context, userID uuid.UUID) (*domain.PlanSubscription, error) {
+	args := m.Called(ctx, userID)
+	sub, _ := args.Get(0).(*domain.PlanSubscription)
+	return sub, args.Error(1)
+}
+
+func (m *MockPlans) Plan(code domain.PlanCode) (domain.Plan, error) {
+	args := m.Called(code)
+	return args.Get(0).(domain.Plan), args.Error(1)
+}
+
+func (m *MockPlans) AssignPlan(ctx context.Context, userID uuid.UUID, to domain.PlanCode, adminID uuid.UUID) (*domain.PlanSubscription, error) {
+	args := m.Called(ctx, userID, to, adminID)
+	sub, _ := args.Get(0).(*domain.PlanSubscription)
+	return sub, args.Error(1)
+}
+
+type MockCollector struct {
+	mock.Mock
+}
+
+func (m *MockCollector) CollectInvoice(ctx context.Context, inv *domain.Invoice) (*domain.Transaction, error) {
+	args := m.Called(ctx, inv)
+	tx, _ := args.Get(0).(*domain.Transaction)
+	return tx, args.Error(1)
+}
+
+type mocks struct {
+	repo      *MockRepository
+	plans     *MockPlans
+	collector *MockCollector
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.plans.AssertExpectations(t)
+	m.collector.AssertExpectations(t)
+}
+
+var (
+	ctx   = context.Background()
+	march = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	april = march.AddDate(0, 1, 0)
+
+	consumer = domain.Plan{Code: domain.PlanConsumer, Name: "Consumer", MonthlyFee: decimal.Zero, FeeCurrency: domain.MWK}
+	premium  = domain.Plan{Code: domain.PlanPremium, Name: "Premium", MonthlyFee: decimal.NewFromInt(5000), FeeCurrency: domain.MWK}
+)
+
+func newService(now *time.Time) (*Service, *mocks) {
+	m := &mocks{repo: new(MockRepository), plans: new(MockPlans), collector: new(MockCollector)}
+	s := NewService(m.repo, m.plans, m.collector, Pricing{
+		Currency:         domain.MWK,
+		IncludedAPICalls: 100,
+		APICallPrice:     decimal.NewFromFloat(0.5),
+		PayoutVolumeRate: decimal.NewFromFloat(0.001),
+		PaymentTerms:     14 * 24 * time.Hour,
+		RetryInterval:    24 * time.Hour,
+		MaxAttempts:      3,
+	}, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, m
+}
+
+// billable has user on plan in March, with charges and usage.
+func billable(m *mocks, user uuid.UUID, plan domain.Plan, charges []domain.BillingCharge, usage []domain.UsageTotal) {
+	m.repo.On("ListBillableUsers", ctx, march, april).Return([]uuid.UUID{user}, nil).Once()
+	m.plans.On("Subscription", ctx, user).Return(&domain.PlanSubscription{UserID: user, Plan: plan.Code}, nil).Once()
+	m.plans.On("Plan", plan.Code).Return(plan, nil).Once()
+	m.repo.On("ListUnbilledCharges", ctx, user, april).Return(charges, nil).Once()
+	m.repo.On("ListUsage", ctx, user, march).Return(usage, nil).Once()
+}
+
+// invoice is user's March invoice as first issued at issued.
+func invoice(user uuid.UUID, issued time.Time) domain.Invoice {
+	id := uuid.New()
+	return domain.Invoice{
+		ID: id, Number: invoiceNumber(march, id), UserID: user, Currency: domain.MWK,
+		PeriodStart: march, PeriodEnd: april, Total: decimal.NewFromInt(5000),
+		Status: domain.InvoiceStatusOpen, CollectionMethod: domain.CollectionWallet,
+		DueAt: issued, NextAttemptAt: &issued, CreatedAt: issued, UpdatedAt: issued,
+	}
+}
+
+func TestGenerateInvoices_PricesPlanUsageAndCharges(t *testing.T) {
+	now := march.Add(9 * 24 * time.Hour)
+	s, m := newService(&now)
+	user := uuid.New()
+
+	m.repo.On("AddUsage", ctx, user, domain.UsageAPICalls, domain.Currency(""), march, decimal.NewFromInt(150)).Return(nil).Once()
+	require.NoError(t, s.RecordUsage(ctx, user, domain.UsageAPICalls, decimal.NewFromInt(150), ""))
+	require.NoError(t, s.RecordUsage(ctx, user, domain.UsageAPICalls, decimal.Zero, ""), "nothing to record")
+
+	var proration domain.BillingCharge
+	m.repo.On("AddCharge", ctx, mock.MatchedBy(func(c *domain.BillingCharge) bool {
+		return c.UserID == user && c.Amount.Equal(decimal.NewFromInt(2500)) && c.Currency == domain.MWK && c.CreatedAt.Equal(now)
+	})).Run(func(args mock.Arguments) { proration = *args.Get(1).(*domain.BillingCharge) }).Return(nil).Once()
+	require.NoError(t, s.PlanChanged(ctx, &domain.PlanChange{
+		UserID: user, From: domain.PlanConsumer, To: domain.PlanPremium,
+		Proration: decimal.NewFromInt(2500), Currency: domain.MWK,
+	}))
+	m.assert(t)
+
+	now = april.Add(time.Hour)
+	billable(m, user, premium, []domain.BillingCharge{proration}, []domain.UsageTotal{
+		{UserID: user, Metric: domain.UsageAPICalls, PeriodStart: march, Quantity: decimal.NewFromInt(150)},
+		{UserID: user, Metric: domain.UsagePayoutVolume, Currency: domain.CNY, PeriodStart: march, Quantity: decimal.NewFromInt(3000)},
+		{UserID: user, Metric: domain.UsagePayoutVolume, Currency: domain.MWK, PeriodStart: march, Quantity: decimal.NewFromInt(200000)},
+	})
+	var created []domain.Currency
+	m.repo.On("CreateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+		// 5000 plan + 2500 proration + 50 calls * 0.5 + 200000 * 0.001
+		return inv.Currency == domain.MWK && inv.Total.Equal(decimal.NewFromInt(7725)) && len(inv.Lines) == 4 &&
+			inv.Status == domain.InvoiceStatusOpen && inv.DueAt.Equal(now) && inv.NextAttemptAt != nil && inv.NextAttemptAt.Equal(now)
+	}), []uuid.UUID{proration.ID}).Run(func(mock.Arguments) { created = append(created, domain.MWK) }).Return(true, nil).Once()
+	m.repo.On("CreateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+		return inv.Currency == domain.CNY && inv.Total.Equal(decimal.NewFromInt(3)) && len(inv.Lines) == 1
+	}), mock.MatchedBy(func(ids []uuid.UUID) bool { return len(ids) == 0 })).
+		Run(func(mock.Arguments) { created = append(created, domain.CNY) }).Return(true, nil).Once()
+	tx := &domain.Transaction{ID: uuid.New(), Reference: "REF-1"}
+	m.collector.On("CollectInvoice", ctx, mock.Anything).Return(tx, nil).Twice()
+	m.repo.On("UpdateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+		return inv.Status == domain.InvoiceStatusPaid && inv.TransactionID != nil && *inv.TransactionID == tx.ID && inv.PaymentReference == "REF-1" &&
+			inv.PaidAt != nil && inv.PaidAt.Equal(now) && inv.NextAttemptAt == nil
+	})).Return(true, nil).Twice()
+
+	n, err := s.GenerateInvoices(ctx, march.Add(15*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "one invoice per currency")
+	assert.Equal(t, []domain.Currency{domain.CNY, domain.MWK}, created)
+	m.assert(t)
+
+	t.Run("a month is invoiced once", func(t *testing.T) {
+		s, m := newService(&now)
+		billable(m, user, premium, nil, nil)
+		m.repo.On("CreateInvoice", ctx, mock.Anything, mock.Anything).Return(false, nil).Once()
+
+		n, err := s.GenerateInvoices(ctx, march)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		m.collector.AssertNotCalled(t, "CollectInvoice", mock.Anything, mock.Anything)
+		m.assert(t)
+	})
+}
+
+func TestGenerateInvoices_IncludedUsageIsFree(t *testing.T) {
+	now := april.Add(time.Hour)
+	s, m := newService(&now)
+	user := uuid.New()
+	billable(m, user, consumer, nil, []domain.UsageTotal{
+		{UserID: user, Metric: domain.UsageAPICalls, PeriodStart: march, Quantity: decimal.NewFromInt(80)},
+	})
+
+	n, err := s.GenerateInvoices(ctx, march)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	m.repo.AssertNotCalled(t, "CreateInvoice", mock.Anything, mock.Anything, mock.Anything)
+	m.assert(t)
+}
+
+func TestCollect_DunningDowngradesAfterMaxAttempts(t *testing.T) {
+	issued := april.Add(time.Hour)
+	user := uuid.New()
+	// due is the invoice as ListDueInvoices finds it after attempts.
+	due := func(attempts int) domain.Invoice {
+		inv := invoice(user, issued)
+		if attempts > 0 {
+			inv.Status, inv.Attempts = domain.InvoiceStatusPastDue, attempts
+		}
+		return inv
+	}
+
+	for _, tt := range []struct {
+		attempts int
+		backoff  time.Duration
+	}{
+		{0, 24 * time.Hour},
+		{1, 48 * time.Hour},
+	} {
+		now := issued.Add(time.Duration(tt.attempts) * 24 * time.Hour)
+		s, m := newService(&now)
+		m.repo.On("ListDueInvoices", ctx, now, dueBatchSize).Return([]domain.Invoice{due(tt.attempts)}, nil).Once()
+		m.collector.On("CollectInvoice", ctx, mock.Anything).Return(nil, pkgerrors.ErrInsufficientBalance).Once()
+		m.repo.On("UpdateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+			return inv.Status == domain.InvoiceStatusPastDue && inv.Attempts == tt.attempts+1 &&
+				inv.NextAttemptAt != nil && inv.NextAttemptAt.Equal(now.Add(tt.backoff)) && inv.LastError != ""
+		})).Return(true, nil).Once()
+
+		require.NoError(t, s.CollectDue(ctx))
+		m.plans.AssertNotCalled(t, "AssignPlan", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		m.assert(t)
+	}
+
+	t.Run("the last attempt", func(t *testing.T) {
+		now := issued.Add(72 * time.Hour)
+		s, m := newService(&now)
+		m.repo.On("ListDueInvoices", ctx, now, dueBatchSize).Return([]domain.Invoice{due(2)}, nil).Once()
+		m.collector.On("CollectInvoice", ctx, mock.Anything).Return(nil, pkgerrors.ErrInsufficientBalance).Once()
+		m.repo.On("UpdateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+			return inv.Status == domain.InvoiceStatusUncollectible && inv.Attempts == 3 && inv.NextAttemptAt == nil
+		})).Return(true, nil).Once()
+		m.plans.On("Subscription", ctx, user).Return(&domain.PlanSubscription{UserID: user, Plan: domain.PlanPremium}, nil).Once()
+		m.plans.On("AssignPlan", ctx, user, domain.PlanConsumer, uuid.Nil).Return(&domain.PlanSubscription{UserID: user, Plan: domain.PlanConsumer}, nil).Once()
+
+		require.NoError(t, s.CollectDue(ctx))
+		m.assert(t)
+	})
+
+	t.Run("settled by another run meanwhile", func(t *testing.T) {
+		now := issued.Add(72 * time.Hour)
+		s, m := newService(&now)
+		m.repo.On("ListDueInvoices", ctx, now, dueBatchSize).Return([]domain.Invoice{due(2)}, nil).Once()
+		m.collector.On("CollectInvoice", ctx, mock.Anything).Return(nil, pkgerrors.ErrInsufficientBalance).Once()
+		m.repo.On("UpdateInvoice", ctx, mock.Anything).Return(false, nil).Once()
+
+		require.NoError(t, s.CollectDue(ctx))
+		m.plans.AssertNotCalled(t, "AssignPlan", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		m.assert(t)
+	})
+}
+
+func TestCollect_NoWalletSwitchesToExternal(t *testing.T) {
+	now := april.Add(time.Hour)
+	s, m := newService(&now)
+	user := uuid.New()
+	inv := invoice(user, now)
+	m.repo.On("ListDueInvoices", ctx, now, dueBatchSize).Return([]domain.Invoice{inv}, nil).Once()
+	m.collector.On("CollectInvoice", ctx, mock.Anything).Return(nil, pkgerrors.ErrWalletNotFound).Once()
+	m.repo.On("UpdateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+		return inv.CollectionMethod == domain.CollectionExternal && inv.Status == domain.InvoiceStatusOpen &&
+			inv.DueAt.Equal(now.Add(14*24*time.Hour)) && inv.NextAttemptAt == nil && inv.Attempts == 0
+	})).Return(true, nil).Once()
+	require.NoError(t, s.CollectDue(ctx))
+	m.assert(t)
+
+	now = now.Add(14 * 24 * time.Hour)
+	inv.CollectionMethod, inv.DueAt, inv.NextAttemptAt = domain.CollectionExternal, now, nil
+	m.repo.On("ListDueInvoices", ctx, now, dueBatchSize).Return([]domain.Invoice{inv}, nil).Once()
+	m.repo.On("UpdateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+		return inv.Status == domain.InvoiceStatusPastDue && inv.UpdatedAt.Equal(now)
+	})).Return(true, nil).Once()
+	require.NoError(t, s.CollectDue(ctx))
+	m.collector.AssertNumberOfCalls(t, "CollectInvoice", 1)
+	m.assert(t)
+}
+
+func TestMarkPaidExternally(t *testing.T) {
+	now := april.Add(time.Hour)
+	admin := uuid.New()
+	inv := invoice(uuid.New(), now)
+	inv.Status = domain.InvoiceStatusPastDue
+
+	s, m := newService(&now)
+	_, err := s.MarkPaidExternally(ctx, inv.ID, " ", admin)
+	assert.ErrorIs(t, err, ErrInvalidReference)
+	m.repo.AssertNotCalled(t, "GetInvoice", mock.Anything, mock.Anything)
+
+	missing := uuid.New()
+	m.repo.On("GetInvoice", ctx, missing).Return(nil, nil).Once()
+	_, err = s.MarkPaidExternally(ctx, missing, "BANK-123", admin)
+	assert.ErrorIs(t, err, ErrInvoiceNotFound)
+
+	m.repo.On("GetInvoice", ctx, inv.ID).Return(&inv, nil).Twice()
+	m.repo.On("UpdateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+		return inv.Status == domain.InvoiceStatusPaid && inv.CollectionMethod == domain.CollectionExternal &&
+			inv.PaymentReference == "BANK-123" && inv.TransactionID == nil
+	})).Return(true, nil).Once()
+	m.repo.On("UpdateInvoice", ctx, mock.Anything).Return(false, nil).Once()
+	paid, err := s.MarkPaidExternally(ctx, inv.ID, " BANK-123 ", admin)
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceStatusPaid, paid.Status)
+	_, err = s.MarkPaidExternally(ctx, inv.ID, "BANK-123", admin)
+	assert.ErrorIs(t, err, ErrInvoiceNotCollectible, "settled by someone else meanwhile")
+
+	settled := *paid
+	m.repo.On("GetInvoice", ctx, settled.ID).Return(&settled, nil).Once()
+	_, err = s.MarkPaidExternally(ctx, settled.ID, "BANK-123", admin)
+	assert.ErrorIs(t, err, ErrInvoiceNotCollectible)
+	m.assert(t)
+}
+
+func TestPayInvoice_OnlyOwnInvoices(t *testing.T) {
+	now := april.Add(time.Hour)
+	s, m := newService(&now)
+	user := uuid.New()
+	inv := invoice(user, now)
+	inv.Status, inv.Attempts = domain.InvoiceStatusPastDue, 1
+	m.repo.On("GetInvoice", ctx, inv.ID).Return(&inv, nil).Times(3)
+
+	_, err := s.PayInvoice(ctx, uuid.New(), inv.ID)
+	assert.ErrorIs(t, err, ErrInvoiceNotFound)
+	m.collector.AssertNotCalled(t, "CollectInvoice", mock.Anything, mock.Anything)
+
+	m.collector.On("CollectInvoice", ctx, mock.Anything).Return(nil, pkgerrors.ErrInsufficientBalance).Once()
+	_, err = s.PayInvoice(ctx, user, inv.ID)
+	assert.ErrorIs(t, err, pkgerrors.ErrInsufficientBalance)
+	m.repo.AssertNotCalled(t, "UpdateInvoice", mock.Anything, mock.Anything)
+
+	tx := &domain.Transaction{ID: uuid.New(), Reference: "REF-1"}
+	m.collector.On("CollectInvoice", ctx, mock.Anything).Return(tx, nil).Once()
+	m.repo.On("UpdateInvoice", ctx, mock.MatchedBy(func(inv *domain.Invoice) bool {
+		// A failed attempt here does not count towards dunning
+		return inv.Status == domain.InvoiceStatusPaid && inv.Attempts == 1 && inv.TransactionID != nil && *inv.TransactionID == tx.ID
+	})).Return(true, nil).Once()
+	paid, err := s.PayInvoice(ctx, user, inv.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvoiceStatusPaid, paid.Status)
+	m.assert(t)
+}
+
+func TestListInvoices_ClampsPaging(t *testing.T) {
+	now := april
+	s, m := newService(&now)
+	user := uuid.New()
+	m.repo.On("ListInvoices", ctx, InvoiceFilter{UserID: &user, Limit: 50, Offset: 0}).Return(nil, nil).Twice()
+
+	_, err := s.ListInvoices(ctx, InvoiceFilter{UserID: &user, Limit: 500, Offset: -1})
+	require.NoError(t, err)
+	_, err = s.ListInvoices(ctx, InvoiceFilter{UserID: &user})
+	require.NoError(t, err)
+	m.assert(t)
+}
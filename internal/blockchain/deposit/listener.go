@@ -0,0 +1,360 @@
+// Package deposit watches our receiving addresses on the settlement networks
+// and credits matched inbound transfers to customer wallets.
+package deposit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	"kyd/internal/wallet"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// IncomingTransfer is a transfer to one of our receiving addresses as reported by a chain.
+type IncomingTransfer struct {
+	TxHash      string
+	FromAddress string
+	ToAddress   string
+	Memo        string
+	Amount      decimal.Decimal
+	Currency    domain.Currency
+	BlockHeight int64
+	BlockHash   string
+}
+
+// Watcher is implemented by blockchain connectors that can report inbound transfers.
+type Watcher interface {
+	LatestHeight(ctx context.Context) (int64, error)
+	BlockHashAt(ctx context.Context, height int64) (string, error)
+	IncomingTransfers(ctx context.Context, addresses []string, fromHeight, toHeight int64) ([]*IncomingTransfer, error)
+}
+
+type Repository interface {
+	// Create inserts the deposit unless the (network, tx_hash, receiving_address)
+	// triple is already known, reporting whether a row was written.
+	Create(ctx context.Context, d *domain.OnchainDeposit) (bool, error)
+	Update(ctx context.Context, d *domain.OnchainDeposit) error
+	FindByStatus(ctx context.Context, network domain.BlockchainNetwork, status domain.OnchainDepositStatus, limit int) ([]*domain.OnchainDeposit, error)
+	GetCursor(ctx context.Context, network domain.BlockchainNetwork) (int64, error)
+	SetCursor(ctx context.Context, network domain.BlockchainNetwork, height int64) error
+}
+
+type WalletRepository interface {
+	FindByAddress(ctx context.Context, address string) (*domain.Wallet, error)
+}
+
+// Creditor credits a wallet; satisfied by wallet.Service.
+type Creditor interface {
+	Deposit(ctx context.Context, req *wallet.DepositRequest) (*domain.Wallet, error)
+}
+
+type network struct {
+	id            domain.BlockchainNetwork
+	watcher       Watcher
+	addresses     []string
+	confirmations int64
+}
+
+type Listener struct {
+	repo     Repository
+	wallets  WalletRepository
+	creditor Creditor
+	logger   logger.Logger
+	pulse    heartbeat.Pulse
+
+	mu       sync.Mutex
+	networks []*network
+}
+
+func NewListener(repo Repository, wallets WalletRepository, creditor Creditor, log logger.Logger) *Listener {
+	return &Listener{
+		repo:     repo,
+		wallets:  wallets,
+		creditor: creditor,
+		logger:   log,
+		pulse:    heartbeat.Nop,
+	}
+}
+
+// WithHeartbeat beats p after every poll.
+func (l *Listener) WithHeartbeat(p heartbeat.Pulse) *Listener {
+	l.pulse = p
+	return l
+}
+
+// Watch registers a network to be scanned for transfers to the given addresses.
+// Deposits are credited once they are buried under the given number of blocks.
+func (l *Listener) Watch(id domain.BlockchainNetwork, w Watcher, addresses []string, confirmations int) {
+	if w == nil || len(addresses) == 0 {
+		return
+	}
+	if confirmations < 1 {
+		confirmations = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.networks = append(l.networks, &network{
+		id:            id,
+		watcher:       w,
+		addresses:     addresses,
+		confirmations: int64(confirmations),
+	})
+}
+
+// Start polls every registered network until the context is cancelled.
+func (l *Listener) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.Poll(ctx); err != nil {
+			l.logger.Error("Deposit listener poll failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		l.pulse.Beat()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll runs one scan/confirm/credit cycle for every registered network.
+func (l *Listener) Poll(ctx context.Context) error {
+	l.mu.Lock()
+	networks := append([]*network(nil), l.networks...)
+	l.mu.Unlock()
+
+	var firstErr error
+	for _, n := range networks {
+		if err := l.pollNetwork(ctx, n); err != nil {
+			l.logger.Error("Deposit scan failed", map[string]interface{}{
+				"network": n.id,
+				"error":   err.Error(),
+			})
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (l *Listener) pollNetwork(ctx context.Context, n *network) error {
+	tip, err := n.watcher.LatestHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("latest height: %w", err)
+	}
+
+	if err := l.scan(ctx, n, tip); err != nil {
+		return err
+	}
+	if err := l.confirm(ctx, n, tip); err != nil {
+		return err
+	}
+	return l.credit(ctx, n)
+}
+
+// scan records new transfers up to the chain tip. The window is re-opened by the
+// confirmation depth so that transfers re-included after a reorg are picked up.
+func (l *Listener) scan(ctx context.Context, n *network, tip int64) error {
+	cursor, err := l.repo.GetCursor(ctx, n.id)
+	if err != nil {
+		return fmt.Errorf("load cursor: %w", err)
+	}
+	from := cursor - n.confirmations + 1
+	if from < 1 {
+		from = 1
+	}
+	if from > tip {
+		return nil
+	}
+
+	transfers, err := n.watcher.IncomingTransfers(ctx, n.addresses, from, tip)
+	if err != nil {
+		return fmt.Errorf("fetch transfers: %w", err)
+	}
+
+	for _, t := range transfers {
+		d := l.newDeposit(ctx, n, t)
+		created, err := l.repo.Create(ctx, d)
+		if err != nil {
+			return fmt.Errorf("record deposit %s: %w", t.TxHash, err)
+		}
+		if created {
+			l.logger.Info("Inbound deposit detected", map[string]interface{}{
+				"network": n.id,
+				"tx_hash": t.TxHash,
+				"amount":  t.Amount,
+				"status":  d.Status,
+			})
+		}
+	}
+
+	return l.repo.SetCursor(ctx, n.id, tip)
+}
+
+func (l *Listener) newDeposit(ctx context.Context, n *network, t *IncomingTransfer) *domain.OnchainDeposit {
+	now := time.Now()
+	d := &domain.OnchainDeposit{
+		ID:               uuid.New(),
+		Network:          n.id,
+		TxHash:           t.TxHash,
+		ReceivingAddress: t.ToAddress,
+		SenderAddress:    t.FromAddress,
+		Memo:             strings.TrimSpace(t.Memo),
+		Amount:           t.Amount,
+		Currency:         t.Currency,
+		BlockHeight:      t.BlockHeight,
+		BlockHash:        t.BlockHash,
+		Status:           domain.OnchainDepositDetected,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	if reason := l.match(ctx, d); reason != "" {
+		d.Status = domain.OnchainDepositUnmatched
+		d.FailureReason = &reason
+	}
+	return d
+}
+
+// match resolves the transfer memo to a customer wallet. Customers quote their
+// wallet number as the memo, so the lookup goes through the wallet address index.
+func (l *Listener) match(ctx context.Context, d *domain.OnchainDeposit) string {
+	if !d.Amount.IsPositive() {
+		return "non-positive amount"
+	}
+	if d.Memo == "" {
+		return "missing memo"
+	}
+	w, err := l.wallets.FindByAddress(ctx, d.Memo)
+	if err != nil || w == nil {
+		return "memo does not match a wallet"
+	}
+	if d.Currency != w.Currency {
+		return fmt.Sprintf("currency %s does not match wallet currency %s", d.Currency, w.Currency)
+	}
+	d.WalletID = &w.ID
+	return ""
+}
+
+// confirm advances detected deposits that have reached the confirmation
+// threshold and orphans any whose block is no longer on the canonical chain.
+func (l *Listener) confirm(ctx context.Context, n *network, tip int64) error {
+	pending, err := l.repo.FindByStatus(ctx, n.id, domain.OnchainDepositDetected, 500)
+	if err != nil {
+		return fmt.Errorf("load detected deposits: %w", err)
+	}
+
+	for _, d := range pending {
+		hash, err := n.watcher.BlockHashAt(ctx, d.BlockHeight)
+		if err != nil {
+			l.logger.Warn("Block lookup failed", map[string]interface{}{
+				"network": n.id,
+				"height":  d.BlockHeight,
+				"error":   err.Error(),
+			})
+			continue
+		}
+
+		now := time.Now()
+		d.UpdatedAt = now
+		if hash != d.BlockHash {
+			reason := "block reorganized out of canonical chain"
+			d.Status = domain.OnchainDepositOrphaned
+			d.FailureReason = &reason
+			l.logger.Warn("Deposit orphaned by reorg", map[string]interface{}{
+				"network": n.id,
+				"tx_hash": d.TxHash,
+				"height":  d.BlockHeight,
+			})
+		} else {
+			d.Confirmations = tip - d.BlockHeight + 1
+			if d.Confirmations >= n.confirmations {
+				d.Status = domain.OnchainDepositConfirmed
+				d.ConfirmedAt = &now
+			}
+		}
+
+		if err := l.repo.Update(ctx, d); err != nil {
+			return fmt.Errorf("update deposit %s: %w", d.ID, err)
+		}
+	}
+	return nil
+}
+
+func (l *Listener) credit(ctx context.Context, n *network) error {
+	confirmed, err := l.repo.FindByStatus(ctx, n.id, domain.OnchainDepositConfirmed, 500)
+	if err != nil {
+		return fmt.Errorf("load confirmed deposits: %w", err)
+	}
+
+	for _, d := range confirmed {
+		if d.WalletID == nil {
+			continue
+		}
+		ref := creditReference(d)
+		_, err := l.creditor.Deposit(ctx, &wallet.DepositRequest{
+			WalletID:  *d.WalletID,
+			Amount:    d.Amount,
+			Currency:  d.Currency,
+			SourceID:  fmt.Sprintf("%s:%s", d.Network, d.TxHash),
+			Reference: ref,
+			Channel:   "blockchain",
+			Metadata: domain.Metadata{
+				"network":           d.Network,
+				"tx_hash":           d.TxHash,
+				"receiving_address": d.ReceivingAddress,
+				"block_height":      d.BlockHeight,
+			},
+		})
+
+		now := time.Now()
+		d.UpdatedAt = now
+		if err != nil {
+			reason := err.Error()
+			d.Status = domain.OnchainDepositFailed
+			d.FailureReason = &reason
+			l.logger.Error("Failed to credit on-chain deposit", map[string]interface{}{
+				"network":   n.id,
+				"tx_hash":   d.TxHash,
+				"wallet_id": d.WalletID,
+				"error":     reason,
+			})
+		} else {
+			d.Status = domain.OnchainDepositCredited
+			d.CreditReference = &ref
+			d.CreditedAt = &now
+			l.logger.Info("On-chain deposit credited", map[string]interface{}{
+				"network":   n.id,
+				"tx_hash":   d.TxHash,
+				"wallet_id": d.WalletID,
+				"amount":    d.Amount,
+			})
+		}
+
+		if err := l.repo.Update(ctx, d); err != nil {
+			return fmt.Errorf("update deposit %s: %w", d.ID, err)
+		}
+	}
+	return nil
+}
+
+func creditReference(d *domain.OnchainDeposit) string {
+	h := d.TxHash
+	if len(h) > 12 {
+		h = h[:12]
+	}
+	return fmt.Sprintf("CHAIN-%s", strings.ToUpper(h))
+}
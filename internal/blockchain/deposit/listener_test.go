@@ -0,0 +1,228 @@
+package deposit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"kyd/internal/domain"
+	"kyd/internal/wallet"
+	"kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Fakes
+
+type fakeChain struct {
+	hashes    []string
+	transfers []*IncomingTransfer
+}
+
+func (c *fakeChain) addBlock(transfers ...*IncomingTransfer) {
+	height := int64(len(c.hashes) + 1)
+	hash := fmt.Sprintf("block-%d-%d", height, len(c.transfers))
+	c.hashes = append(c.hashes, hash)
+	for _, t := range transfers {
+		t.BlockHeight = height
+		t.BlockHash = hash
+		c.transfers = append(c.transfers, t)
+	}
+}
+
+func (c *fakeChain) LatestHeight(_ context.Context) (int64, error) {
+	return int64(len(c.hashes)), nil
+}
+
+func (c *fakeChain) BlockHashAt(_ context.Context, height int64) (string, error) {
+	return c.hashes[height-1], nil
+}
+
+func (c *fakeChain) IncomingTransfers(_ context.Context, _ []string, from, to int64) ([]*IncomingTransfer, error) {
+	var out []*IncomingTransfer
+	for _, t := range c.transfers {
+		if t.BlockHeight >= from && t.BlockHeight <= to {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+type memoryRepository struct {
+	deposits map[string]*domain.OnchainDeposit
+	cursors  map[domain.BlockchainNetwork]int64
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		deposits: map[string]*domain.OnchainDeposit{},
+		cursors:  map[domain.BlockchainNetwork]int64{},
+	}
+}
+
+func (r *memoryRepository) Create(_ context.Context, d *domain.OnchainDeposit) (bool, error) {
+	key := string(d.Network) + d.TxHash + d.ReceivingAddress
+	if _, ok := r.deposits[key]; ok {
+		return false, nil
+	}
+	cp := *d
+	r.deposits[key] = &cp
+	return true, nil
+}
+
+func (r *memoryRepository) Update(_ context.Context, d *domain.OnchainDeposit) error {
+	cp := *d
+	r.deposits[string(d.Network)+d.TxHash+d.ReceivingAddress] = &cp
+	return nil
+}
+
+func (r *memoryRepository) FindByStatus(_ context.Context, network domain.BlockchainNetwork, status domain.OnchainDepositStatus, _ int) ([]*domain.OnchainDeposit, error) {
+	var out []*domain.OnchainDeposit
+	for _, d := range r.deposits {
+		if d.Network == network && d.Status == status {
+			cp := *d
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) GetCursor(_ context.Context, network domain.BlockchainNetwork) (int64, error) {
+	return r.cursors[network], nil
+}
+
+func (r *memoryRepository) SetCursor(_ context.Context, network domain.BlockchainNetwork, height int64) error {
+	r.cursors[network] = height
+	return nil
+}
+
+func (r *memoryRepository) get(txHash string) *domain.OnchainDeposit {
+	for _, d := range r.deposits {
+		if d.TxHash == txHash {
+			return d
+		}
+	}
+	return nil
+}
+
+// Mocks
+
+type MockWalletRepository struct {
+	mock.Mock
+}
+
+func (m *MockWalletRepository) FindByAddress(ctx context.Context, address string) (*domain.Wallet, error) {
+	args := m.Called(ctx, address)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+
+type MockCreditor struct {
+	mock.Mock
+}
+
+func (m *MockCreditor) Deposit(ctx context.Context, req *wallet.DepositRequest) (*domain.Wallet, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+
+const receiving = "GKYDRECEIVE"
+
+func TestListener_CreditsAfterConfirmations(t *testing.T) {
+	ctx := context.Background()
+	chain := &fakeChain{}
+	repo := newMemoryRepository()
+	wallets := new(MockWalletRepository)
+	creditor := new(MockCreditor)
+
+	w := &domain.Wallet{ID: uuid.New(), Currency: domain.MWK}
+	wallets.On("FindByAddress", ctx, "1234567890").Return(w, nil)
+	creditor.On("Deposit", ctx, mock.MatchedBy(func(req *wallet.DepositRequest) bool {
+		return req.WalletID == w.ID && req.Amount.Equal(decimal.NewFromInt(250)) && req.Channel == "blockchain"
+	})).Return(w, nil).Once()
+
+	l := NewListener(repo, wallets, creditor, logger.New("test"))
+	l.Watch(domain.NetworkStellar, chain, []string{receiving}, 3)
+
+	chain.addBlock(&IncomingTransfer{TxHash: "tx1", ToAddress: receiving, Memo: " 1234567890 ", Amount: decimal.NewFromInt(250), Currency: domain.MWK})
+	assert.NoError(t, l.Poll(ctx))
+	assert.Equal(t, domain.OnchainDepositDetected, repo.get("tx1").Status)
+	assert.Equal(t, int64(1), repo.get("tx1").Confirmations)
+
+	chain.addBlock()
+	assert.NoError(t, l.Poll(ctx))
+	assert.Equal(t, domain.OnchainDepositDetected, repo.get("tx1").Status)
+
+	chain.addBlock()
+	assert.NoError(t, l.Poll(ctx))
+	d := repo.get("tx1")
+	assert.Equal(t, domain.OnchainDepositCredited, d.Status)
+	assert.NotNil(t, d.CreditReference)
+	assert.NotNil(t, d.CreditedAt)
+
+	// Rescanning the confirmation window must not credit twice.
+	chain.addBlock()
+	assert.NoError(t, l.Poll(ctx))
+	creditor.AssertExpectations(t)
+}
+
+func TestListener_UnmatchedMemo(t *testing.T) {
+	ctx := context.Background()
+	chain := &fakeChain{}
+	repo := newMemoryRepository()
+	wallets := new(MockWalletRepository)
+	creditor := new(MockCreditor)
+
+	wallets.On("FindByAddress", ctx, "unknown").Return(nil, errors.ErrWalletNotFound)
+
+	l := NewListener(repo, wallets, creditor, logger.New("test"))
+	l.Watch(domain.NetworkRipple, chain, []string{receiving}, 1)
+
+	chain.addBlock(
+		&IncomingTransfer{TxHash: "tx-unknown", ToAddress: receiving, Memo: "unknown", Amount: decimal.NewFromInt(10), Currency: domain.MWK},
+		&IncomingTransfer{TxHash: "tx-nomemo", ToAddress: receiving, Amount: decimal.NewFromInt(10), Currency: domain.MWK},
+	)
+	assert.NoError(t, l.Poll(ctx))
+
+	assert.Equal(t, domain.OnchainDepositUnmatched, repo.get("tx-unknown").Status)
+	assert.Equal(t, domain.OnchainDepositUnmatched, repo.get("tx-nomemo").Status)
+	creditor.AssertNotCalled(t, "Deposit", mock.Anything, mock.Anything)
+}
+
+func TestListener_OrphansReorgedDeposit(t *testing.T) {
+	ctx := context.Background()
+	chain := &fakeChain{}
+	repo := newMemoryRepository()
+	wallets := new(MockWalletRepository)
+	creditor := new(MockCreditor)
+
+	w := &domain.Wallet{ID: uuid.New(), Currency: domain.MWK}
+	wallets.On("FindByAddress", ctx, "1234567890").Return(w, nil)
+
+	l := NewListener(repo, wallets, creditor, logger.New("test"))
+	l.Watch(domain.NetworkRipple, chain, []string{receiving}, 3)
+
+	chain.addBlock(&IncomingTransfer{TxHash: "tx1", ToAddress: receiving, Memo: "1234567890", Amount: decimal.NewFromInt(5), Currency: domain.MWK})
+	assert.NoError(t, l.Poll(ctx))
+
+	// Replace block 1 with a competing block that does not contain the transfer.
+	chain.hashes[0] = "block-1-fork"
+	chain.transfers = nil
+	chain.addBlock()
+	assert.NoError(t, l.Poll(ctx))
+
+	d := repo.get("tx1")
+	assert.Equal(t, domain.OnchainDepositOrphaned, d.Status)
+	assert.NotNil(t, d.FailureReason)
+	creditor.AssertNotCalled(t, "Deposit", mock.Anything, mock.Anything)
+}
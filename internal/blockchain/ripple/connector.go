@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"sync"
 
 	"kyd/internal/blockchain/banking"
 	"kyd/internal/domain"
@@ -18,6 +19,10 @@ import (
 type Connector struct {
 	Node      *BlockchainNode
 	SecretKey string
+
+	// mu guards Node, whose maps are read by the deposit watcher while
+	// settlements are being submitted.
+	mu sync.RWMutex
 }
 
 // NewConnector initializes a new local blockchain node for settlement.
@@ -63,6 +68,9 @@ func NewConnector(_, secretKey string) (*Connector, error) {
 
 // SubmitSettlement submits a settlement transaction to the blockchain.
 func (c *Connector) SubmitSettlement(_ context.Context, s *domain.Settlement) (*settlement.SettlementResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// Convert decimal amount to integer drops (e.g., x 1,000,000)
 	amount := s.TotalAmount.Mul(decimal.NewFromInt(1000000)).IntPart()
 
@@ -92,6 +100,7 @@ func (c *Connector) SubmitSettlement(_ context.Context, s *domain.Settlement) (*
 	receiver := NewPublicKey(AlgoDilithium, pub2)
 
 	tx := NewTransaction(sender, receiver, amount, 1) // Nonce should be managed
+	tx.GasPrice = gasPriceForBid(s.NetworkFee, tx.GasLimit)
 
 	// Enrich with ISO 20022 Metadata
 	// In a real scenario, this data comes from the settlement request or external source
@@ -142,11 +151,15 @@ func (c *Connector) SubmitSettlement(_ context.Context, s *domain.Settlement) (*
 	return &settlement.SettlementResult{
 		TxHash:    tx.TxID,
 		Confirmed: false, // Will be confirmed by poller
+		Fee:       decimal.NewFromInt(tx.GasPrice * int64(tx.GasLimit)).Div(dropsPerUnit),
 	}, nil
 }
 
 // CheckConfirmation checks if a transaction is confirmed.
 func (c *Connector) CheckConfirmation(_ context.Context, txHash string) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	if _, ok := c.Node.TxIndex[txHash]; ok {
 		return true, nil
 	}
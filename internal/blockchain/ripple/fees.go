@@ -0,0 +1,53 @@
+package ripple
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/settlement"
+
+	"github.com/shopspring/decimal"
+)
+
+// standardGasLimit matches the gas limit NewTransaction assigns to transfers.
+const standardGasLimit = 21000
+
+// dropsPerUnit converts between drops and whole settlement currency units.
+var dropsPerUnit = decimal.NewFromInt(1000000)
+
+// EstimateFee prices a standard transfer at the current mempool load. The base
+// gas price doubles as the mempool approaches capacity.
+func (c *Connector) EstimateFee(_ context.Context, _ *domain.Settlement) (*settlement.FeeQuote, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	congestion := c.congestion()
+	gasPrice := decimal.NewFromFloat(1 + congestion)
+	fee := gasPrice.Mul(decimal.NewFromInt(standardGasLimit)).Div(dropsPerUnit)
+
+	return &settlement.FeeQuote{BaseFee: fee, Congestion: congestion}, nil
+}
+
+// congestion reports mempool utilisation. Callers must hold c.mu.
+func (c *Connector) congestion() float64 {
+	if c.Node.MempoolMaxSize <= 0 {
+		return 0
+	}
+	load := float64(len(c.Node.PendingTransactions)) / float64(c.Node.MempoolMaxSize)
+	if load > 1 {
+		return 1
+	}
+	return load
+}
+
+// gasPriceForBid converts a fee bid into a per-unit gas price in drops, never below one drop.
+func gasPriceForBid(bid decimal.Decimal, gasLimit uint64) int64 {
+	if !bid.IsPositive() || gasLimit == 0 {
+		return 1
+	}
+	price := bid.Mul(dropsPerUnit).Div(decimal.NewFromInt(int64(gasLimit))).Ceil().IntPart()
+	if price < 1 {
+		return 1
+	}
+	return price
+}
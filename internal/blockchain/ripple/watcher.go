@@ -0,0 +1,89 @@
+package ripple
+
+import (
+	"context"
+	"fmt"
+
+	"kyd/internal/blockchain/deposit"
+	"kyd/internal/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+// LatestHeight returns the block number of the current chain head.
+func (c *Connector) LatestHeight(_ context.Context) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Node.HeadBlock == nil {
+		return 0, nil
+	}
+	return int64(c.Node.HeadBlock.BlockNumber), nil
+}
+
+// BlockHashAt returns the hash of the canonical block at the given height.
+func (c *Connector) BlockHashAt(_ context.Context, height int64) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	b := c.blockAt(height)
+	if b == nil || int64(b.BlockNumber) != height {
+		return "", fmt.Errorf("no block at height %d", height)
+	}
+	return b.ComputeHash(), nil
+}
+
+// IncomingTransfers returns transfers to the given addresses in canonical blocks
+// within [fromHeight, toHeight]. The memo is carried in the transaction data field.
+func (c *Connector) IncomingTransfers(_ context.Context, addresses []string, fromHeight, toHeight int64) ([]*deposit.IncomingTransfer, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	watched := make(map[string]bool, len(addresses))
+	for _, a := range addresses {
+		watched[a] = true
+	}
+
+	var out []*deposit.IncomingTransfer
+	for b := c.blockAt(toHeight); b != nil && int64(b.BlockNumber) >= fromHeight; b = c.Node.Blocks[b.ParentHash] {
+		blockHash := b.ComputeHash()
+		for _, tx := range b.Transactions {
+			if tx.Receiver == nil || tx.IsPrivate {
+				continue
+			}
+			to := tx.Receiver.Address()
+			if !watched[to] {
+				continue
+			}
+			from := ""
+			if tx.Sender != nil {
+				from = tx.Sender.Address()
+			}
+			out = append(out, &deposit.IncomingTransfer{
+				TxHash:      tx.TxID,
+				FromAddress: from,
+				ToAddress:   to,
+				Memo:        string(tx.Data),
+				Amount:      decimal.New(tx.Amount, -6),
+				Currency:    domain.Currency(tx.Currency),
+				BlockHeight: int64(b.BlockNumber),
+				BlockHash:   blockHash,
+			})
+		}
+		if b.BlockNumber == 0 {
+			break
+		}
+	}
+	return out, nil
+}
+
+// blockAt walks back from the head to the canonical block at height, clamping
+// heights above the head to the head itself. Callers must hold c.mu.
+func (c *Connector) blockAt(height int64) *Block {
+	b := c.Node.HeadBlock
+	for b != nil && int64(b.BlockNumber) > height {
+		if b.BlockNumber == 0 {
+			return nil
+		}
+		b = c.Node.Blocks[b.ParentHash]
+	}
+	return b
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"kyd/internal/domain"
@@ -16,6 +17,11 @@ import (
 // Connector provides integration with the Stellar-like AegisNet Blockchain.
 type Connector struct {
 	Simulator *AegisNetSimulator
+
+	// mu guards the simulator state and the ledger sequence, which the deposit
+	// watcher reads concurrently with settlement submission.
+	mu     sync.RWMutex
+	ledger []*MicroBlock
 }
 
 // NewConnector initializes a new local AegisNet simulator for settlement.
@@ -29,6 +35,9 @@ func NewConnector(_, _ string, _ bool) (*Connector, error) {
 
 // SubmitSettlement submits a settlement transaction to the blockchain.
 func (c *Connector) SubmitSettlement(_ context.Context, s *domain.Settlement) (*settlement.SettlementResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// Convert decimal amount to integer atomic units (e.g., x 1,000,000)
 	amount := s.TotalAmount.Mul(decimal.NewFromInt(1000000)).IntPart()
 
@@ -62,25 +71,22 @@ func (c *Connector) SubmitSettlement(_ context.Context, s *domain.Settlement) (*
 
 	mb := &MicroBlock{
 		BlockID:      fmt.Sprintf("mb_%d_%d", shardID, time.Now().UnixNano()),
+		ShardID:      shardID,
 		Transactions: []*ConfidentialTransaction{tx},
 		Timestamp:    float64(time.Now().Unix()),
 		Weight:       1.0,
 		ProposerID:   proposerID,
 	}
 
-	// Validate Block using Consensus Engine (Smart Contracts & Compliance)
-	if !c.Simulator.Consensus.ValidateBlock(mb) {
-		return nil, fmt.Errorf("blockchain validation failed: smart contract or compliance violation")
+	fee := chargedFee(s.NetworkFee, c.congestion())
+	if err := c.commitBlock(shard, mb); err != nil {
+		return nil, err
 	}
 
-	shard.MicroBlocks[mb.BlockID] = mb
-
-	// Update tips to point to this new block
-	shard.DAGTips = []string{mb.BlockID}
-
 	return &settlement.SettlementResult{
 		TxHash:    tx.TxID,
 		Confirmed: true, // Auto-confirmed in this simulation
+		Fee:       fee,
 	}, nil
 }
 
@@ -89,3 +95,23 @@ func (c *Connector) CheckConfirmation(_ context.Context, txHash string) (bool, e
 	// In simulator, we treat everything as confirmed once in a microblock
 	return true, nil
 }
+
+// commitBlock validates a microblock and appends it to the shard DAG and the
+// connector's ledger sequence. Callers must hold c.mu.
+func (c *Connector) commitBlock(shard *ShardState, mb *MicroBlock) error {
+	// Validate Block using Consensus Engine (Smart Contracts & Compliance)
+	if !c.Simulator.Consensus.ValidateBlock(mb) {
+		return fmt.Errorf("blockchain validation failed: smart contract or compliance violation")
+	}
+
+	if len(c.ledger) > 0 {
+		mb.ParentRefs = []string{c.ledger[len(c.ledger)-1].BlockID}
+	}
+	shard.MicroBlocks[mb.BlockID] = mb
+
+	// Update tips to point to this new block
+	shard.DAGTips = []string{mb.BlockID}
+
+	c.ledger = append(c.ledger, mb)
+	return nil
+}
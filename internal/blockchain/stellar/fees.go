@@ -0,0 +1,60 @@
+package stellar
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/settlement"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// ledgerCapacityPerMinute is the microblock rate at which we treat the network as saturated.
+	ledgerCapacityPerMinute = 600
+)
+
+var stellarBaseFee = decimal.NewFromFloat(0.01)
+
+// EstimateFee prices a submission from the recent microblock rate. Fees surge
+// up to 5x the base fee as the network approaches capacity.
+func (c *Connector) EstimateFee(_ context.Context, _ *domain.Settlement) (*settlement.FeeQuote, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	congestion := c.congestion()
+	return &settlement.FeeQuote{
+		BaseFee:    surgeFee(congestion),
+		Congestion: congestion,
+	}, nil
+}
+
+// congestion reports the share of ledger capacity used in the last minute.
+// Callers must hold c.mu.
+func (c *Connector) congestion() float64 {
+	cutoff := float64(time.Now().Add(-time.Minute).Unix())
+	recent := 0
+	for i := len(c.ledger) - 1; i >= 0 && c.ledger[i].Timestamp >= cutoff; i-- {
+		recent++
+	}
+	load := float64(recent) / ledgerCapacityPerMinute
+	if load > 1 {
+		return 1
+	}
+	return load
+}
+
+func surgeFee(congestion float64) decimal.Decimal {
+	return stellarBaseFee.Mul(decimal.NewFromFloat(1 + 4*congestion))
+}
+
+// chargedFee is what the network takes for a submission: the current surge
+// fee, bounded by the bid when one was made.
+func chargedFee(bid decimal.Decimal, congestion float64) decimal.Decimal {
+	fee := surgeFee(congestion)
+	if bid.IsPositive() && bid.LessThan(fee) {
+		return bid
+	}
+	return fee
+}
@@ -26,6 +26,7 @@ type ConfidentialTransaction struct {
 	ReceiverZKAddress string
 	Amount            int64
 	AssetType         string
+	Memo              string
 	ZKProof           string
 	Timestamp         float64
 	Transparent       bool
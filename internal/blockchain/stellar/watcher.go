@@ -0,0 +1,109 @@
+package stellar
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"kyd/internal/blockchain/deposit"
+	"kyd/internal/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+// LatestHeight returns the number of microblocks committed through this connector.
+func (c *Connector) LatestHeight(_ context.Context) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return int64(len(c.ledger)), nil
+}
+
+// BlockHashAt returns the hash of the microblock at the given 1-based height.
+func (c *Connector) BlockHashAt(_ context.Context, height int64) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if height < 1 || height > int64(len(c.ledger)) {
+		return "", fmt.Errorf("no block at height %d", height)
+	}
+	return c.ledger[height-1].ComputeHash(), nil
+}
+
+// IncomingTransfers returns transparent transfers to the given addresses in the
+// height range [fromHeight, toHeight]. Confidential transfers cannot be attributed
+// to a receiving address and are skipped.
+func (c *Connector) IncomingTransfers(_ context.Context, addresses []string, fromHeight, toHeight int64) ([]*deposit.IncomingTransfer, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	watched := make(map[string]bool, len(addresses))
+	for _, a := range addresses {
+		watched[a] = true
+	}
+
+	if fromHeight < 1 {
+		fromHeight = 1
+	}
+	if toHeight > int64(len(c.ledger)) {
+		toHeight = int64(len(c.ledger))
+	}
+
+	var out []*deposit.IncomingTransfer
+	for h := fromHeight; h <= toHeight; h++ {
+		mb := c.ledger[h-1]
+		blockHash := mb.ComputeHash()
+		for _, tx := range mb.Transactions {
+			if !tx.Transparent || !watched[tx.ReceiverZKAddress] {
+				continue
+			}
+			out = append(out, &deposit.IncomingTransfer{
+				TxHash:      tx.TxID,
+				FromAddress: tx.SenderZKAddress,
+				ToAddress:   tx.ReceiverZKAddress,
+				Memo:        tx.Memo,
+				Amount:      decimal.New(tx.Amount, -6),
+				Currency:    domain.Currency(tx.AssetType),
+				BlockHeight: h,
+				BlockHash:   blockHash,
+			})
+		}
+	}
+	return out, nil
+}
+
+// SimulateIncomingPayment commits a transparent payment to one of our receiving
+// addresses. It stands in for an external sender in development environments.
+func (c *Connector) SimulateIncomingPayment(from, to, memo string, amount decimal.Decimal, currency domain.Currency) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx := &ConfidentialTransaction{
+		TxID:              fmt.Sprintf("tx_in_%d", time.Now().UnixNano()),
+		SenderZKAddress:   from,
+		ReceiverZKAddress: to,
+		Amount:            amount.Mul(decimal.NewFromInt(1000000)).IntPart(),
+		AssetType:         string(currency),
+		Memo:              memo,
+		Timestamp:         float64(time.Now().Unix()),
+		Transparent:       true,
+	}
+
+	shardID := rand.Intn(c.Simulator.NumShards)
+	proposerID := "val_0"
+	if len(c.Simulator.Validators) > 0 {
+		proposerID = c.Simulator.Validators[0].ValidatorID
+	}
+
+	mb := &MicroBlock{
+		BlockID:      fmt.Sprintf("mb_%d_%d", shardID, time.Now().UnixNano()),
+		ShardID:      shardID,
+		Transactions: []*ConfidentialTransaction{tx},
+		Timestamp:    float64(time.Now().Unix()),
+		Weight:       1.0,
+		ProposerID:   proposerID,
+	}
+	if err := c.commitBlock(c.Simulator.Shards[shardID], mb); err != nil {
+		return "", err
+	}
+	return tx.TxID, nil
+}
@@ -0,0 +1,358 @@
+// Package broadcast delivers operator-composed messages to segments of users.
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidBroadcast = errors.New("invalid broadcast")
+	ErrNotFound         = errors.New("broadcast not found")
+	ErrNotCancellable   = errors.New("broadcast can no longer be cancelled")
+)
+
+// Repository persists broadcasts, resolves their audience and records deliveries.
+type Repository interface {
+	CreateBroadcast(ctx context.Context, b *domain.Broadcast) error
+	GetBroadcast(ctx context.Context, id uuid.UUID) (*domain.Broadcast, error)
+	ListBroadcasts(ctx context.Context, limit, offset int) ([]domain.Broadcast, int, error)
+	// CancelBroadcast cancels a broadcast that has not started sending and
+	// reports whether it did.
+	CancelBroadcast(ctx context.Context, id uuid.UUID) (bool, error)
+	// ClaimDueBroadcasts moves scheduled broadcasts whose time has come to
+	// sending and returns them, so concurrent workers never pick the same one.
+	// Broadcasts left in sending by a worker that died are reclaimed after a lease.
+	ClaimDueBroadcasts(ctx context.Context, now time.Time, limit int) ([]domain.Broadcast, error)
+	CompleteBroadcast(ctx context.Context, id uuid.UUID, at time.Time) error
+	// ListSegmentRecipients pages through matching users ordered by ID,
+	// skipping users that already have a delivery for the broadcast.
+	ListSegmentRecipients(ctx context.Context, broadcastID uuid.UUID, segment domain.BroadcastSegment, limit int) ([]uuid.UUID, error)
+	CountSegmentRecipients(ctx context.Context, segment domain.BroadcastSegment) (int, error)
+	CreateDelivery(ctx context.Context, d *domain.BroadcastDelivery) error
+	GetBroadcastStats(ctx context.Context, id uuid.UUID) (*domain.BroadcastStats, error)
+}
+
+// SegmentSource looks up saved segments.
+type SegmentSource interface {
+	Get(ctx context.Context, id uuid.UUID) (*domain.Segment, error)
+}
+
+// Notifier is the part of the notification service used for delivery.
+type Notifier interface {
+	SendRaw(ctx context.Context, n *notification.Notification) error
+}
+
+const (
+	defaultPollInterval = 30 * time.Second
+	recipientBatchSize  = 500
+)
+
+type Service struct {
+	repo     Repository
+	notifier Notifier
+	segments SegmentSource
+	logger   logger.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, notifier Notifier, log logger.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		notifier: notifier,
+		logger:   log,
+		interval: defaultPollInterval,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// WithPollInterval sets how often the worker looks for due broadcasts.
+func (s *Service) WithPollInterval(d time.Duration) *Service {
+	if d > 0 {
+		s.interval = d
+	}
+	return s
+}
+
+// WithSegments lets broadcasts target saved segments.
+func (s *Service) WithSegments(segments SegmentSource) *Service {
+	s.segments = segments
+	return s
+}
+
+func (s *Service) savedSegment(ctx context.Context, id uuid.UUID) (*domain.Segment, error) {
+	if s.segments == nil {
+		return nil, fmt.Errorf("%w: saved segments are not available", ErrInvalidBroadcast)
+	}
+	seg, err := s.segments.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBroadcast, err)
+	}
+	return seg, nil
+}
+
+// CreateRequest is an operator's request to send a broadcast.
+type CreateRequest struct {
+	Title       string
+	Message     string
+	Segment     domain.BroadcastSegment
+	SegmentID   *uuid.UUID // a saved segment to target instead of Segment
+	Channels    []string
+	ScheduledAt *time.Time
+	CreatedBy   *uuid.UUID
+}
+
+var validChannels = map[string]notification.ChannelType{
+	"email": notification.ChannelEmail,
+	"sms":   notification.ChannelSMS,
+	"push":  notification.ChannelPush,
+}
+
+// Create validates and schedules a broadcast. Without a scheduled time it is
+// picked up by the next worker run.
+func (s *Service) Create(ctx context.Context, req *CreateRequest) (*domain.Broadcast, error) {
+	title := strings.TrimSpace(req.Title)
+	message := strings.TrimSpace(req.Message)
+	if title == "" || message == "" {
+		return nil, fmt.Errorf("%w: title and message are required", ErrInvalidBroadcast)
+	}
+
+	channels := make([]string, 0, len(req.Channels))
+	seen := make(map[string]bool)
+	for _, ch := range req.Channels {
+		ch = strings.ToLower(strings.TrimSpace(ch))
+		if _, ok := validChannels[ch]; !ok {
+			return nil, fmt.Errorf("%w: unsupported channel %q", ErrInvalidBroadcast, ch)
+		}
+		if !seen[ch] {
+			seen[ch] = true
+			channels = append(channels, ch)
+		}
+	}
+	if len(channels) == 0 {
+		channels = append(channels, "email")
+	}
+
+	segment := req.Segment
+	if req.SegmentID != nil {
+		// The saved segment's criteria are copied, so that editing the
+		// segment later does not change who a scheduled broadcast reaches.
+		saved, err := s.savedSegment(ctx, *req.SegmentID)
+		if err != nil {
+			return nil, err
+		}
+		segment = saved.Criteria
+	}
+	if err := segment.Normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBroadcast, err)
+	}
+
+	now := s.now()
+	scheduledAt := now
+	if req.ScheduledAt != nil {
+		if req.ScheduledAt.Before(now.Add(-time.Minute)) {
+			return nil, fmt.Errorf("%w: scheduled_at is in the past", ErrInvalidBroadcast)
+		}
+		scheduledAt = *req.ScheduledAt
+	}
+
+	b := &domain.Broadcast{
+		ID:          uuid.New(),
+		Title:       title,
+		Message:     message,
+		Segment:     segment,
+		SegmentID:   req.SegmentID,
+		Channels:    channels,
+		Status:      domain.BroadcastStatusScheduled,
+		ScheduledAt: scheduledAt,
+		CreatedBy:   req.CreatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.CreateBroadcast(ctx, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.Broadcast, *domain.BroadcastStats, error) {
+	b, err := s.repo.GetBroadcast(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats, err := s.Stats(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, stats, nil
+}
+
+func (s *Service) List(ctx context.Context, limit, offset int) ([]domain.Broadcast, int, error) {
+	return s.repo.ListBroadcasts(ctx, limit, offset)
+}
+
+// Cancel stops a broadcast that has not started sending.
+func (s *Service) Cancel(ctx context.Context, id uuid.UUID) error {
+	ok, err := s.repo.CancelBroadcast(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := s.repo.GetBroadcast(ctx, id); err != nil {
+			return err
+		}
+		return ErrNotCancellable
+	}
+	return nil
+}
+
+// Preview reports how many users a segment currently matches.
+func (s *Service) Preview(ctx context.Context, segment domain.BroadcastSegment) (int, error) {
+	return s.repo.CountSegmentRecipients(ctx, segment)
+}
+
+// Stats returns delivery and read counts with their rates.
+func (s *Service) Stats(ctx context.Context, id uuid.UUID) (*domain.BroadcastStats, error) {
+	stats, err := s.repo.GetBroadcastStats(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Recipients > 0 {
+		stats.DeliveryRate = float64(stats.Delivered) / float64(stats.Recipients)
+	}
+	if stats.Delivered > 0 {
+		stats.ReadRate = float64(stats.Read) / float64(stats.Delivered)
+	}
+	return stats, nil
+}
+
+// Start runs the delivery worker until Stop is called.
+func (s *Service) Start() {
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.RunDue(context.Background())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Broadcast worker started", map[string]interface{}{"interval": s.interval.String()})
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// RunDue delivers every broadcast whose scheduled time has passed.
+func (s *Service) RunDue(ctx context.Context) {
+	due, err := s.repo.ClaimDueBroadcasts(ctx, s.now(), 10)
+	if err != nil {
+		s.logger.Error("Failed to claim due broadcasts", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	for i := range due {
+		if err := s.deliver(ctx, &due[i]); err != nil {
+			// The broadcast stays in sending; deliveries already made are
+			// recorded, so it resumes where it stopped once reclaimed.
+			s.logger.Error("Broadcast delivery interrupted", map[string]interface{}{
+				"error":        err.Error(),
+				"broadcast_id": due[i].ID,
+			})
+		}
+	}
+}
+
+func (s *Service) deliver(ctx context.Context, b *domain.Broadcast) error {
+	channels := make([]notification.ChannelType, 0, len(b.Channels))
+	for _, ch := range b.Channels {
+		if c, ok := validChannels[ch]; ok {
+			channels = append(channels, c)
+		}
+	}
+	if len(channels) == 0 {
+		channels = append(channels, notification.ChannelEmail)
+	}
+
+	var delivered, failed int
+	for {
+		recipients, err := s.repo.ListSegmentRecipients(ctx, b.ID, b.Segment, recipientBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			break
+		}
+		for _, userID := range recipients {
+			d := s.deliverTo(ctx, b, userID, channels)
+			if err := s.repo.CreateDelivery(ctx, d); err != nil {
+				return err
+			}
+			if d.Status == domain.BroadcastDeliveryDelivered {
+				delivered++
+			} else {
+				failed++
+			}
+		}
+	}
+
+	if err := s.repo.CompleteBroadcast(ctx, b.ID, s.now()); err != nil {
+		return err
+	}
+	s.logger.Info("Broadcast sent", map[string]interface{}{
+		"broadcast_id": b.ID,
+		"delivered":    delivered,
+		"failed":       failed,
+	})
+	return nil
+}
+
+func (s *Service) deliverTo(ctx context.Context, b *domain.Broadcast, userID uuid.UUID, channels []notification.ChannelType) *domain.BroadcastDelivery {
+	n := &notification.Notification{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Type:     "BROADCAST",
+		Channel:  channels[0],
+		Channels: channels[1:],
+		Priority: notification.PriorityNormal,
+		Subject:  b.Title,
+		Body:     b.Message,
+		Metadata: map[string]interface{}{
+			"broadcast_id": b.ID.String(),
+		},
+		CreatedAt: s.now(),
+	}
+	d := &domain.BroadcastDelivery{
+		ID:          uuid.New(),
+		BroadcastID: b.ID,
+		UserID:      userID,
+		Status:      domain.BroadcastDeliveryDelivered,
+		CreatedAt:   n.CreatedAt,
+	}
+	if err := s.notifier.SendRaw(ctx, n); err != nil {
+		msg := err.Error()
+		d.Status = domain.BroadcastDeliveryFailed
+		d.Error = &msg
+		return d
+	}
+	d.NotificationID = &n.ID
+	return d
+}
@@ -0,0 +1,220 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryRepository keeps broadcasts in memory; every user matches every segment.
+type memoryRepository struct {
+	broadcasts map[uuid.UUID]*domain.Broadcast
+	users      []uuid.UUID
+	deliveries []*domain.BroadcastDelivery
+	batchSizes []int
+}
+
+func newMemoryRepository(users int) *memoryRepository {
+	r := &memoryRepository{broadcasts: make(map[uuid.UUID]*domain.Broadcast)}
+	for i := 0; i < users; i++ {
+		r.users = append(r.users, uuid.New())
+	}
+	return r
+}
+
+func (r *memoryRepository) CreateBroadcast(_ context.Context, b *domain.Broadcast) error {
+	r.broadcasts[b.ID] = b
+	return nil
+}
+
+func (r *memoryRepository) GetBroadcast(_ context.Context, id uuid.UUID) (*domain.Broadcast, error) {
+	if b, ok := r.broadcasts[id]; ok {
+		return b, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryRepository) ListBroadcasts(context.Context, int, int) ([]domain.Broadcast, int, error) {
+	return nil, 0, nil
+}
+
+func (r *memoryRepository) CancelBroadcast(_ context.Context, id uuid.UUID) (bool, error) {
+	b, ok := r.broadcasts[id]
+	if !ok || b.Status != domain.BroadcastStatusScheduled {
+		return false, nil
+	}
+	b.Status = domain.BroadcastStatusCancelled
+	return true, nil
+}
+
+func (r *memoryRepository) ClaimDueBroadcasts(_ context.Context, now time.Time, _ int) ([]domain.Broadcast, error) {
+	var due []domain.Broadcast
+	for _, b := range r.broadcasts {
+		if b.Status == domain.BroadcastStatusScheduled && !b.ScheduledAt.After(now) {
+			b.Status = domain.BroadcastStatusSending
+			due = append(due, *b)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryRepository) CompleteBroadcast(_ context.Context, id uuid.UUID, at time.Time) error {
+	r.broadcasts[id].Status = domain.BroadcastStatusSent
+	r.broadcasts[id].CompletedAt = &at
+	return nil
+}
+
+func (r *memoryRepository) ListSegmentRecipients(_ context.Context, broadcastID uuid.UUID, _ domain.BroadcastSegment, limit int) ([]uuid.UUID, error) {
+	done := make(map[uuid.UUID]bool)
+	for _, d := range r.deliveries {
+		if d.BroadcastID == broadcastID {
+			done[d.UserID] = true
+		}
+	}
+	var ids []uuid.UUID
+	for _, u := range r.users {
+		if !done[u] && len(ids) < limit {
+			ids = append(ids, u)
+		}
+	}
+	r.batchSizes = append(r.batchSizes, len(ids))
+	return ids, nil
+}
+
+func (r *memoryRepository) CountSegmentRecipients(context.Context, domain.BroadcastSegment) (int, error) {
+	return len(r.users), nil
+}
+
+func (r *memoryRepository) CreateDelivery(_ context.Context, d *domain.BroadcastDelivery) error {
+	r.deliveries = append(r.deliveries, d)
+	return nil
+}
+
+func (r *memoryRepository) GetBroadcastStats(context.Context, uuid.UUID) (*domain.BroadcastStats, error) {
+	return &domain.BroadcastStats{Recipients: 4, Delivered: 3, Failed: 1, Read: 1}, nil
+}
+
+type recordingNotifier struct {
+	sent   []*notification.Notification
+	failed map[uuid.UUID]bool
+}
+
+func (n *recordingNotifier) SendRaw(_ context.Context, msg *notification.Notification) error {
+	if n.failed[msg.UserID] {
+		return errors.New("provider unavailable")
+	}
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func TestCreate_Validation(t *testing.T) {
+	s := NewService(newMemoryRepository(0), &recordingNotifier{}, logger.NewNop())
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, &CreateRequest{Title: " ", Message: "Body"})
+	assert.ErrorIs(t, err, ErrInvalidBroadcast)
+
+	_, err = s.Create(ctx, &CreateRequest{Title: "Maintenance", Message: "Body", Channels: []string{"fax"}})
+	assert.ErrorIs(t, err, ErrInvalidBroadcast)
+
+	past := time.Now().Add(-time.Hour)
+	_, err = s.Create(ctx, &CreateRequest{Title: "Maintenance", Message: "Body", ScheduledAt: &past})
+	assert.ErrorIs(t, err, ErrInvalidBroadcast)
+
+	b, err := s.Create(ctx, &CreateRequest{
+		Title:    "New corridor",
+		Message:  "You can now send to Zambia.",
+		Channels: []string{"SMS", "email", "sms"},
+		Segment:  domain.BroadcastSegment{Countries: []string{" mw "}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.BroadcastStatusScheduled, b.Status)
+	assert.Equal(t, []string{"sms", "email"}, []string(b.Channels))
+	assert.Equal(t, []string{"MW"}, b.Segment.Countries)
+}
+
+type fakeSegments map[uuid.UUID]*domain.Segment
+
+func (f fakeSegments) Get(_ context.Context, id uuid.UUID) (*domain.Segment, error) {
+	if seg, ok := f[id]; ok {
+		return seg, nil
+	}
+	return nil, errors.New("segment not found")
+}
+
+func TestCreate_SavedSegment(t *testing.T) {
+	saved := &domain.Segment{ID: uuid.New(), Criteria: domain.SegmentCriteria{Countries: []string{"ZM"}, MinAccountAgeDays: 30}}
+	s := NewService(newMemoryRepository(0), &recordingNotifier{}, logger.NewNop()).
+		WithSegments(fakeSegments{saved.ID: saved})
+	ctx := context.Background()
+
+	b, err := s.Create(ctx, &CreateRequest{Title: "Zambia", Message: "Body", SegmentID: &saved.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, saved.ID, *b.SegmentID)
+	assert.Equal(t, []string{"ZM"}, b.Segment.Countries)
+	assert.Equal(t, 30, b.Segment.MinAccountAgeDays)
+
+	missing := uuid.New()
+	_, err = s.Create(ctx, &CreateRequest{Title: "Zambia", Message: "Body", SegmentID: &missing})
+	assert.ErrorIs(t, err, ErrInvalidBroadcast)
+
+	_, err = s.Create(ctx, &CreateRequest{Title: "Bad", Message: "Body", Segment: domain.BroadcastSegment{MinAccountAgeDays: -1}})
+	assert.ErrorIs(t, err, ErrInvalidBroadcast)
+}
+
+func TestRunDue_DeliversInBatches(t *testing.T) {
+	repo := newMemoryRepository(recipientBatchSize + 2)
+	notifier := &recordingNotifier{failed: map[uuid.UUID]bool{repo.users[0]: true}}
+	s := NewService(repo, notifier, logger.NewNop())
+	ctx := context.Background()
+
+	later := time.Now().Add(time.Hour)
+	future, err := s.Create(ctx, &CreateRequest{Title: "Later", Message: "Not yet", ScheduledAt: &later})
+	assert.NoError(t, err)
+	b, err := s.Create(ctx, &CreateRequest{Title: "Maintenance", Message: "Down at 02:00 UTC", Channels: []string{"email", "sms"}})
+	assert.NoError(t, err)
+
+	s.RunDue(ctx)
+
+	assert.Equal(t, domain.BroadcastStatusSent, repo.broadcasts[b.ID].Status)
+	assert.Equal(t, domain.BroadcastStatusScheduled, repo.broadcasts[future.ID].Status)
+	assert.Equal(t, []int{recipientBatchSize, 2, 0}, repo.batchSizes)
+	assert.Len(t, repo.deliveries, len(repo.users))
+	assert.Len(t, notifier.sent, len(repo.users)-1)
+
+	first := notifier.sent[0]
+	assert.Equal(t, notification.ChannelEmail, first.Channel)
+	assert.Equal(t, []notification.ChannelType{notification.ChannelSMS}, first.Channels)
+	assert.Equal(t, b.ID.String(), first.Metadata["broadcast_id"])
+
+	failed := repo.deliveries[0]
+	assert.Equal(t, domain.BroadcastDeliveryFailed, failed.Status)
+	assert.Nil(t, failed.NotificationID)
+	assert.Equal(t, domain.BroadcastDeliveryDelivered, repo.deliveries[1].Status)
+	assert.Equal(t, notifier.sent[0].ID, *repo.deliveries[1].NotificationID)
+}
+
+func TestCancelAndStats(t *testing.T) {
+	repo := newMemoryRepository(0)
+	s := NewService(repo, &recordingNotifier{}, logger.NewNop())
+	ctx := context.Background()
+
+	b, err := s.Create(ctx, &CreateRequest{Title: "Maintenance", Message: "Body"})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Cancel(ctx, b.ID))
+	assert.ErrorIs(t, s.Cancel(ctx, b.ID), ErrNotCancellable)
+	assert.ErrorIs(t, s.Cancel(ctx, uuid.New()), ErrNotFound)
+
+	stats, err := s.Stats(ctx, b.ID)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.75, stats.DeliveryRate, 1e-9)
+	assert.InDelta(t, 1.0/3, stats.ReadRate, 1e-9)
+}
@@ -0,0 +1,233 @@
+// Package bulkfile defines the bulk payment file formats corporates may
+// send: the KYD JSON and CSV layouts and ISO 20022 pain.001. Every format
+// is versioned, and each version keeps its own parser, so a corporate on an
+// older version keeps working when a newer one is introduced.
+package bulkfile
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type Format string
+
+const (
+	FormatJSON    Format = "json"
+	FormatCSV     Format = "csv"
+	FormatPain001 Format = "pain.001"
+)
+
+// MaxPayments is the most payments one file may carry, as for POST
+// /payments/bulk.
+const MaxPayments = 100
+
+// LineError is one problem found in a file. Line is the line of the file
+// for CSV and the 1-based position of the payment for JSON and pain.001; it
+// is 0 for problems with the file as a whole.
+type LineError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e LineError) String() string {
+	if e.Field == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Message)
+}
+
+// File is a parsed bulk payment file.
+type File struct {
+	Format  Format
+	Version string
+	// Reference is the sender's own identifier for the file, when the
+	// format carries one.
+	Reference string
+	Payments  []payment.PaymentItem
+}
+
+type parser func(data []byte) (*File, []LineError)
+
+// versions holds the parser of every supported version of each format.
+// Retire a version only once no corporate still sends it.
+var versions = map[Format]map[string]parser{
+	FormatJSON: {"1": parseJSONv1},
+	FormatCSV:  {"1": parseCSVv1},
+	FormatPain001: {
+		"001.001.03": parsePain001,
+		"001.001.09": parsePain001,
+	},
+}
+
+// Supported lists the supported versions of a format, oldest first.
+func Supported(f Format) []string {
+	var out []string
+	for v := range versions[f] {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Parse detects the format and version of a file and parses it. It returns
+// every problem found rather than stopping at the first, so that the
+// sender can fix a file in one go; a file with any error must not be paid.
+func Parse(name string, data []byte) (*File, []LineError) {
+	format := Detect(name, data)
+	version, errs := detectVersion(format, data)
+	if errs != nil {
+		return nil, errs
+	}
+	parse, ok := versions[format][version]
+	if !ok {
+		return nil, []LineError{{Field: "version", Message: fmt.Sprintf(
+			"unsupported %s version %q; supported: %s", format, version, strings.Join(Supported(format), ", "))}}
+	}
+	f, errs := parse(data)
+	if f != nil {
+		f.Format = format
+		f.Version = version
+	}
+	if len(errs) > 0 {
+		return f, errs
+	}
+	return f, nil
+}
+
+// Detect picks the format from the file name, or from the content when the
+// extension does not say.
+func Detect(name string, data []byte) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".csv":
+		return FormatCSV
+	case ".xml":
+		return FormatPain001
+	}
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return FormatPain001
+	}
+	return FormatCSV
+}
+
+func detectVersion(f Format, data []byte) (string, []LineError) {
+	switch f {
+	case FormatJSON:
+		return jsonVersion(data)
+	case FormatCSV:
+		return csvVersion(data)
+	default:
+		return painVersion(data)
+	}
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// item holds one payment as written in a file, before it is checked.
+type item struct {
+	line                int
+	receiverID          string
+	amount              string
+	currency            string
+	destinationCurrency string
+	description         string
+}
+
+// check validates one payment against the bulk payment schema.
+func (it item) check() (payment.PaymentItem, []LineError) {
+	var out payment.PaymentItem
+	var errs []LineError
+	fail := func(field, msg string) {
+		errs = append(errs, LineError{Line: it.line, Field: field, Message: msg})
+	}
+
+	if id, err := uuid.Parse(strings.TrimSpace(it.receiverID)); err != nil || id == uuid.Nil {
+		fail("receiver_id", "must be a user ID")
+	} else {
+		out.ReceiverID = id
+	}
+	if amt, err := decimal.NewFromString(strings.TrimSpace(it.amount)); err != nil {
+		fail("amount", "must be a decimal number")
+	} else if !amt.IsPositive() {
+		fail("amount", "must be greater than zero")
+	} else if !amt.Equal(amt.Round(2)) {
+		fail("amount", "must have at most two decimal places")
+	} else {
+		out.Amount = amt
+	}
+	if c := strings.TrimSpace(it.currency); !currencyCode.MatchString(c) {
+		fail("currency", "must be an ISO 4217 code")
+	} else {
+		out.Currency = domain.Currency(c)
+	}
+	if c := strings.TrimSpace(it.destinationCurrency); c != "" {
+		if !currencyCode.MatchString(c) {
+			fail("destination_currency", "must be an ISO 4217 code")
+		} else {
+			out.DestinationCurrency = domain.Currency(c)
+		}
+	}
+	if d := strings.TrimSpace(it.description); len(d) > 255 {
+		fail("description", "must be at most 255 characters")
+	} else {
+		out.Description = d
+	}
+	return out, errs
+}
+
+// checkAll validates every payment and the file's size.
+func checkAll(items []item) ([]payment.PaymentItem, []LineError) {
+	var errs []LineError
+	switch {
+	case len(items) == 0:
+		errs = append(errs, LineError{Message: "file has no payments"})
+	case len(items) > MaxPayments:
+		errs = append(errs, LineError{Message: fmt.Sprintf("file has %d payments; at most %d are allowed", len(items), MaxPayments)})
+	}
+	payments := make([]payment.PaymentItem, 0, len(items))
+	for _, it := range items {
+		p, perrs := it.check()
+		errs = append(errs, perrs...)
+		payments = append(payments, p)
+	}
+	return payments, errs
+}
+
+// checkControls compares the count and control sum declared by a file with
+// its payments. Either declaration may be absent.
+func checkControls(line int, declaredCount, declaredSum string, payments []payment.PaymentItem) []LineError {
+	var errs []LineError
+	if declaredCount != "" {
+		var n int
+		if _, err := fmt.Sscan(declaredCount, &n); err != nil || n != len(payments) {
+			errs = append(errs, LineError{Line: line, Field: "count", Message: fmt.Sprintf("declares %s payments but the file has %d", declaredCount, len(payments))})
+		}
+	}
+	if declaredSum != "" {
+		sum := decimal.Zero
+		for _, p := range payments {
+			sum = sum.Add(p.Amount)
+		}
+		want, err := decimal.NewFromString(strings.TrimSpace(declaredSum))
+		if err != nil || !want.Equal(sum) {
+			errs = append(errs, LineError{Line: line, Field: "control_sum", Message: fmt.Sprintf("declares a total of %s but the payments add up to %s", declaredSum, sum)})
+		}
+	}
+	return errs
+}
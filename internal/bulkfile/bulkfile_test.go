@@ -0,0 +1,154 @@
+package bulkfile
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	alice = "6f1c1a52-63a4-4d1e-9d6f-3f4f1c2a9b10"
+	bob   = "0b7e3a44-2c1d-4a8e-8f0e-5d6c7b8a9f21"
+)
+
+func TestParse_JSON(t *testing.T) {
+	t.Run("unversioned files are version 1", func(t *testing.T) {
+		f, errs := Parse("payroll.json", []byte(`{"payments":[{"receiver_id":"`+alice+`","amount":150,"currency":"MWK"}]}`))
+		require.Nil(t, errs)
+		assert.Equal(t, FormatJSON, f.Format)
+		assert.Equal(t, "1", f.Version)
+		require.Len(t, f.Payments, 1)
+		assert.True(t, f.Payments[0].Amount.Equal(decimal.NewFromInt(150)))
+	})
+
+	t.Run("unknown versions are rejected", func(t *testing.T) {
+		_, errs := Parse("payroll.json", []byte(`{"version":7,"payments":[]}`))
+		require.Len(t, errs, 1)
+		assert.Equal(t, "version", errs[0].Field)
+		assert.Contains(t, errs[0].Message, "supported: 1")
+	})
+
+	t.Run("every bad payment is reported", func(t *testing.T) {
+		_, errs := Parse("payroll.json", []byte(`{"version":1,"payments":[
+			{"receiver_id":"`+alice+`","amount":"150.00","currency":"MWK"},
+			{"receiver_id":"nobody","amount":"-1","currency":"mwk"},
+			{"receiver_id":"`+bob+`","amount":"1.005","currency":"MWK","memo":"x"}
+		]}`))
+		assert.Contains(t, errs, LineError{Line: 2, Field: "receiver_id", Message: "must be a user ID"})
+		assert.Contains(t, errs, LineError{Line: 2, Field: "amount", Message: "must be greater than zero"})
+		assert.Contains(t, errs, LineError{Line: 2, Field: "currency", Message: "must be an ISO 4217 code"})
+		assert.Contains(t, errs, LineError{Line: 3, Field: "amount", Message: "must have at most two decimal places"})
+		var unknown bool
+		for _, e := range errs {
+			unknown = unknown || (e.Line == 3 && strings.Contains(e.Message, "memo"))
+		}
+		assert.True(t, unknown, "unknown fields are reported: %v", errs)
+	})
+}
+
+func TestParse_CSV(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		f, errs := Parse("payroll.csv", []byte("H,KYDBULK,1,PAYROLL-05\n"+
+			"D,"+alice+",150.00,MWK,,Salary\n"+
+			"D,"+bob+",75.50,MWK,ZAR,\"Salary, May\"\n"+
+			"T,2,225.50\n"))
+		require.Nil(t, errs)
+		assert.Equal(t, FormatCSV, f.Format)
+		assert.Equal(t, "PAYROLL-05", f.Reference)
+		require.Len(t, f.Payments, 2)
+		assert.Equal(t, "ZAR", string(f.Payments[1].DestinationCurrency))
+		assert.Equal(t, "Salary, May", f.Payments[1].Description)
+	})
+
+	t.Run("errors name their line", func(t *testing.T) {
+		_, errs := Parse("payroll.csv", []byte("H,KYDBULK,1\n"+
+			"D,"+alice+",150.00,MWK,,Salary\n"+
+			"D,"+bob+",abc,MWK,,\n"+
+			"X,what\n"+
+			"T,3,150.00\n"))
+		assert.Contains(t, errs, LineError{Line: 3, Field: "amount", Message: "must be a decimal number"})
+		assert.Contains(t, errs, LineError{Line: 4, Message: `unknown record type "X"`})
+		assert.Contains(t, errs, LineError{Line: 5, Field: "count", Message: "declares 3 payments but the file has 2"})
+	})
+
+	t.Run("a file cut short is rejected", func(t *testing.T) {
+		_, errs := Parse("payroll.csv", []byte("H,KYDBULK,1\nD,"+alice+",150.00,MWK,,Salary\n"))
+		assert.Contains(t, errs, LineError{Message: "file has no trailer; it may have been cut short"})
+	})
+
+	t.Run("control sum must match", func(t *testing.T) {
+		_, errs := Parse("payroll.csv", []byte("H,KYDBULK,1\nD,"+alice+",150.00,MWK,,Salary\nT,1,160.00\n"))
+		require.Len(t, errs, 1)
+		assert.Equal(t, "control_sum", errs[0].Field)
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		_, errs := Parse("payroll.csv", []byte("D,"+alice+",150.00,MWK,,Salary\n"))
+		require.Len(t, errs, 1)
+		assert.Equal(t, 1, errs[0].Line)
+	})
+
+	t.Run("too many payments", func(t *testing.T) {
+		var b strings.Builder
+		b.WriteString("H,KYDBULK,1\n")
+		for i := 0; i <= MaxPayments; i++ {
+			b.WriteString("D," + alice + ",1.00,MWK,,\n")
+		}
+		fmt.Fprintf(&b, "T,%d,%d.00\n", MaxPayments+1, MaxPayments+1)
+		_, errs := Parse("payroll.csv", []byte(b.String()))
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Message, "at most 100")
+	})
+}
+
+func painFile(version, ctrlSum string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.` + version + `">
+  <CstmrCdtTrfInitn>
+    <GrpHdr><MsgId>PAYROLL-05</MsgId><NbOfTxs>2</NbOfTxs><CtrlSum>` + ctrlSum + `</CtrlSum></GrpHdr>
+    <PmtInf>
+      <PmtInfId>B1</PmtInfId><NbOfTxs>2</NbOfTxs>
+      <CdtTrfTxInf>
+        <Amt><InstdAmt Ccy="MWK">150.00</InstdAmt></Amt>
+        <CdtrAcct><Id><Othr><Id>` + alice + `</Id></Othr></Id></CdtrAcct>
+        <RmtInf><Ustrd>Salary</Ustrd></RmtInf>
+      </CdtTrfTxInf>
+      <CdtTrfTxInf>
+        <Amt><InstdAmt Ccy="MWK">75.50</InstdAmt></Amt>
+        <CdtrAcct><Id><Othr><Id>` + bob + `</Id></Othr></Id></CdtrAcct>
+      </CdtTrfTxInf>
+    </PmtInf>
+  </CstmrCdtTrfInitn>
+</Document>`
+}
+
+func TestParse_Pain001(t *testing.T) {
+	for _, v := range []string{"001.03", "001.09"} {
+		f, errs := Parse("payroll.xml", []byte(painFile(v, "225.50")))
+		require.Nil(t, errs, v)
+		assert.Equal(t, FormatPain001, f.Format)
+		assert.Equal(t, "001."+v, f.Version)
+		assert.Equal(t, "PAYROLL-05", f.Reference)
+		require.Len(t, f.Payments, 2)
+		assert.Equal(t, alice, f.Payments[0].ReceiverID.String())
+		assert.Equal(t, "Salary", f.Payments[0].Description)
+	}
+
+	_, errs := Parse("payroll.xml", []byte(painFile("001.03", "300.00")))
+	require.Len(t, errs, 1)
+	assert.Equal(t, "control_sum", errs[0].Field)
+
+	_, errs = Parse("payroll.xml", []byte(painFile("001.02", "225.50")))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "supported: 001.001.03, 001.001.09")
+}
+
+func TestDetect_SniffsContentWithoutExtension(t *testing.T) {
+	assert.Equal(t, FormatJSON, Detect("upload", []byte(" {\"payments\":[]}")))
+	assert.Equal(t, FormatPain001, Detect("upload", []byte("<?xml version=\"1.0\"?><Document/>")))
+	assert.Equal(t, FormatCSV, Detect("upload", []byte("H,KYDBULK,1\n")))
+}
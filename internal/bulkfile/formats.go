@@ -0,0 +1,251 @@
+package bulkfile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSON, version 1: the body of POST /payments/bulk, optionally with
+// "version": 1 and a "reference".
+//
+//	{"version": 1, "reference": "PAYROLL-2024-05", "payments": [
+//	  {"receiver_id": "...", "amount": "150.00", "currency": "MWK", "description": "Salary"}
+//	]}
+
+func jsonVersion(data []byte) (string, []LineError) {
+	var head struct {
+		Version json.RawMessage `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return "", []LineError{{Message: "not valid JSON: " + err.Error()}}
+	}
+	if len(head.Version) == 0 || string(head.Version) == "null" {
+		return "1", nil // files from before versioning
+	}
+	return strings.Trim(string(head.Version), `"`), nil
+}
+
+type jsonItemV1 struct {
+	ReceiverID          string      `json:"receiver_id"`
+	Amount              json.Number `json:"amount"`
+	Currency            string      `json:"currency"`
+	DestinationCurrency string      `json:"destination_currency"`
+	Description         string      `json:"description"`
+}
+
+func parseJSONv1(data []byte) (*File, []LineError) {
+	var doc struct {
+		Version   json.RawMessage   `json:"version"`
+		Reference string            `json:"reference"`
+		SenderID  json.RawMessage   `json:"sender_id"` // ignored; the drop's owner pays
+		Payments  []json.RawMessage `json:"payments"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, []LineError{{Message: "invalid file: " + err.Error()}}
+	}
+	var errs []LineError
+	items := make([]item, 0, len(doc.Payments))
+	for i, raw := range doc.Payments {
+		var it jsonItemV1
+		d := json.NewDecoder(bytes.NewReader(raw))
+		d.DisallowUnknownFields()
+		d.UseNumber()
+		if err := d.Decode(&it); err != nil {
+			errs = append(errs, LineError{Line: i + 1, Message: "invalid payment: " + err.Error()})
+		}
+		items = append(items, item{
+			line:                i + 1,
+			receiverID:          it.ReceiverID,
+			amount:              string(it.Amount),
+			currency:            it.Currency,
+			destinationCurrency: it.DestinationCurrency,
+			description:         it.Description,
+		})
+	}
+	payments, perrs := checkAll(items)
+	return &File{Reference: doc.Reference, Payments: payments}, append(errs, perrs...)
+}
+
+// CSV, version 1: a header record, one detail record per payment and a
+// trailer with the payment count and the sum of the amounts.
+//
+//	H,KYDBULK,1,PAYROLL-2024-05
+//	D,<receiver_id>,150.00,MWK,,Salary
+//	T,1,150.00
+
+const csvMagic = "KYDBULK"
+
+func newCSVReader(data []byte) *csv.Reader {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	return r
+}
+
+func csvVersion(data []byte) (string, []LineError) {
+	rec, err := newCSVReader(data).Read()
+	if err != nil || len(rec) < 3 || rec[0] != "H" || rec[1] != csvMagic {
+		return "", []LineError{{Line: 1, Message: "first line must be the header H," + csvMagic + ",<version>"}}
+	}
+	return strings.TrimSpace(rec[2]), nil
+}
+
+func parseCSVv1(data []byte) (*File, []LineError) {
+	r := newCSVReader(data)
+	f := &File{}
+	var (
+		errs    []LineError
+		items   []item
+		trailer []string
+		tLine   int
+	)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			line := 0
+			if errors.As(err, &perr) {
+				line = perr.Line
+			}
+			errs = append(errs, LineError{Line: line, Message: "unreadable line: " + err.Error()})
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		if trailer != nil {
+			errs = append(errs, LineError{Line: line, Message: "nothing may follow the trailer"})
+			continue
+		}
+		switch rec[0] {
+		case "H":
+			if line != 1 {
+				errs = append(errs, LineError{Line: line, Message: "only the first line may be a header"})
+			} else if len(rec) > 3 {
+				f.Reference = strings.TrimSpace(rec[3])
+			}
+		case "D":
+			if len(rec) != 6 {
+				errs = append(errs, LineError{Line: line, Message: fmt.Sprintf("detail line has %d fields; want 6", len(rec))})
+				continue
+			}
+			items = append(items, item{
+				line:                line,
+				receiverID:          rec[1],
+				amount:              rec[2],
+				currency:            rec[3],
+				destinationCurrency: rec[4],
+				description:         rec[5],
+			})
+		case "T":
+			if len(rec) != 3 {
+				errs = append(errs, LineError{Line: line, Message: fmt.Sprintf("trailer has %d fields; want 3", len(rec))})
+				trailer = []string{}
+				continue
+			}
+			trailer, tLine = rec, line
+		default:
+			errs = append(errs, LineError{Line: line, Message: fmt.Sprintf("unknown record type %q", rec[0])})
+		}
+	}
+	payments, perrs := checkAll(items)
+	errs = append(errs, perrs...)
+	switch {
+	case trailer == nil:
+		errs = append(errs, LineError{Message: "file has no trailer; it may have been cut short"})
+	case len(trailer) == 3:
+		errs = append(errs, checkControls(tLine, strings.TrimSpace(trailer[1]), strings.TrimSpace(trailer[2]), payments)...)
+	}
+	f.Payments = payments
+	return f, errs
+}
+
+// ISO 20022 pain.001 (customer credit transfer initiation), versions
+// 001.001.03 and 001.001.09. Each CdtTrfTxInf is one payment to the KYD
+// user whose ID is the creditor account's Othr/Id.
+
+const painNamespace = "urn:iso:std:iso:20022:tech:xsd:pain."
+
+type painDocument struct {
+	XMLName  xml.Name `xml:"Document"`
+	Initiate struct {
+		GrpHdr struct {
+			MsgID   string `xml:"MsgId"`
+			NbOfTxs string `xml:"NbOfTxs"`
+			CtrlSum string `xml:"CtrlSum"`
+		} `xml:"GrpHdr"`
+		PmtInf []struct {
+			NbOfTxs     string `xml:"NbOfTxs"`
+			CtrlSum     string `xml:"CtrlSum"`
+			CdtTrfTxInf []struct {
+				Amt struct {
+					InstdAmt struct {
+						Ccy   string `xml:"Ccy,attr"`
+						Value string `xml:",chardata"`
+					} `xml:"InstdAmt"`
+				} `xml:"Amt"`
+				CdtrAcct struct {
+					ID struct {
+						Othr struct {
+							ID string `xml:"Id"`
+						} `xml:"Othr"`
+					} `xml:"Id"`
+				} `xml:"CdtrAcct"`
+				RmtInf struct {
+					Ustrd []string `xml:"Ustrd"`
+				} `xml:"RmtInf"`
+			} `xml:"CdtTrfTxInf"`
+		} `xml:"PmtInf"`
+	} `xml:"CstmrCdtTrfInitn"`
+}
+
+func painVersion(data []byte) (string, []LineError) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return "", []LineError{{Message: "not valid XML: " + err.Error()}}
+	}
+	if root.XMLName.Local != "Document" || !strings.HasPrefix(root.XMLName.Space, painNamespace+"001.") {
+		return "", []LineError{{Message: fmt.Sprintf("not a pain.001 document (namespace %q)", root.XMLName.Space)}}
+	}
+	return strings.TrimPrefix(root.XMLName.Space, painNamespace), nil
+}
+
+func parsePain001(data []byte) (*File, []LineError) {
+	var doc painDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, []LineError{{Message: "invalid file: " + err.Error()}}
+	}
+	var items []item
+	var errs []LineError
+	for _, inf := range doc.Initiate.PmtInf {
+		first := len(items)
+		for _, tx := range inf.CdtTrfTxInf {
+			items = append(items, item{
+				line:        len(items) + 1,
+				receiverID:  tx.CdtrAcct.ID.Othr.ID,
+				amount:      tx.Amt.InstdAmt.Value,
+				currency:    tx.Amt.InstdAmt.Ccy,
+				description: strings.Join(tx.RmtInf.Ustrd, " "),
+			})
+		}
+		// Each block declares its own count and sum. Item errors are
+		// reported once, for the whole file, below.
+		block, _ := checkAll(items[first:])
+		errs = append(errs, checkControls(0, strings.TrimSpace(inf.NbOfTxs), strings.TrimSpace(inf.CtrlSum), block)...)
+	}
+	payments, perrs := checkAll(items)
+	errs = append(errs, perrs...)
+	errs = append(errs, checkControls(0, strings.TrimSpace(doc.Initiate.GrpHdr.NbOfTxs), strings.TrimSpace(doc.Initiate.GrpHdr.CtrlSum), payments)...)
+	return &File{Reference: doc.Initiate.GrpHdr.MsgID, Payments: payments}, errs
+}
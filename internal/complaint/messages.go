@@ -0,0 +1,59 @@
+package complaint
+
+import (
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+)
+
+// Languages complaints are taken in and answered in.
+const (
+	LanguageEnglish  = "en"
+	LanguageChichewa = "ny"
+)
+
+// phrases are what complainants are told, in one language. Each takes the
+// reference, the days to acknowledgement, the resolution deadline and the
+// resolution, in that order.
+type phrases struct {
+	received     string
+	acknowledged string
+	resolved     string
+	dateLayout   string
+}
+
+var messages = map[string]phrases{
+	LanguageEnglish: {
+		received:     "We have received your complaint %[1]s. We will respond within %[2]d day(s) and aim to resolve it by %[3]s.",
+		acknowledged: "Your complaint %[1]s is being looked into. We aim to resolve it by %[3]s.",
+		resolved:     "Your complaint %[1]s has been resolved: %[4]s If you are not satisfied, you may refer it to the Reserve Bank of Malawi.",
+		dateLayout:   "2 January 2006",
+	},
+	LanguageChichewa: {
+		received:     "Talandira dandaulo lanu %[1]s. Tikuyankhani pasanathe masiku %[2]d, ndipo tikufuna kulithetsa pofika %[3]s.",
+		acknowledged: "Dandaulo lanu %[1]s likuunikidwa. Tikufuna kulithetsa pofika %[3]s.",
+		resolved:     "Dandaulo lanu %[1]s lathetsedwa: %[4]s Ngati simukukhutitsidwa, mutha kupereka dandaulo lanu ku Reserve Bank of Malawi.",
+		dateLayout:   "02/01/2006",
+	},
+}
+
+// message is what the complainant is told on event, in the complaint's
+// language.
+func message(c *domain.Complaint, event string, acknowledgeWithin time.Duration) string {
+	p, ok := messages[c.Language]
+	if !ok {
+		p = messages[LanguageEnglish]
+	}
+	var format string
+	switch event {
+	case EventComplaintReceived:
+		format = p.received
+	case EventComplaintAcknowledged:
+		format = p.acknowledged
+	default:
+		format = p.resolved
+	}
+	days := int((acknowledgeWithin + 24*time.Hour - 1) / (24 * time.Hour))
+	return fmt.Sprintf(format, c.Reference, days, c.ResolveBy.Format(p.dateLayout), c.Resolution)
+}
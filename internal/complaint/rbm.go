@@ -0,0 +1,181 @@
+package complaint
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"kyd/internal/domain"
+)
+
+// returnHeader is the layout of the quarterly complaints return: one line
+// per complaint handled in the quarter, whether received in it or still open
+// from before, with its status as at the quarter's end.
+var returnHeader = []string{
+	"institution_code", "reporting_period", "complaint_reference", "date_received", "channel",
+	"complainant_type", "category", "transaction_reference", "amount", "currency", "status",
+	"date_acknowledged", "acknowledged_within_sla", "date_resolved", "days_to_resolve",
+	"resolved_within_sla", "outcome", "root_cause", "redress_amount",
+}
+
+// ParsePeriod returns the quarter a period such as "2026-Q3" names, as
+// [start, end) in UTC.
+func ParsePeriod(period string) (start, end time.Time, err error) {
+	var year, quarter int
+	if n, _ := fmt.Sscanf(period, "%4d-Q%1d", &year, &quarter); n != 2 || quarter < 1 || quarter > 4 || len(period) != 7 {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %q is not a quarter such as 2026-Q1", ErrInvalidPeriod, period)
+	}
+	start = time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 3, 0), nil
+}
+
+// periodOf names the quarter t falls in.
+func periodOf(t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+}
+
+// Return is the complaints return for period. Returns for quarters that
+// have ended are kept once generated, so the one filed is the one
+// downloaded again; the current quarter's is built afresh each time.
+func (s *Service) Return(ctx context.Context, period string) (*domain.ComplaintReturn, error) {
+	start, end, err := ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	if !start.Before(now) {
+		return nil, fmt.Errorf("%w: %s has not started", ErrInvalidPeriod, period)
+	}
+	if stored, err := s.repo.GetReturn(ctx, period); err != nil || stored != nil {
+		return stored, err
+	}
+	r, err := s.buildReturn(ctx, period, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if end.After(now) {
+		return r, nil
+	}
+	saved, err := s.repo.SaveReturn(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return s.repo.GetReturn(ctx, period)
+	}
+	return r, nil
+}
+
+// GenerateDueReturn generates and keeps the return for the last quarter
+// that has ended, if it has none yet, reporting whether it did.
+func (s *Service) GenerateDueReturn(ctx context.Context) (bool, error) {
+	period := periodOf(s.now().UTC().AddDate(0, -3, 0))
+	stored, err := s.repo.GetReturn(ctx, period)
+	if err != nil || stored != nil {
+		return false, err
+	}
+	start, end, err := ParsePeriod(period)
+	if err != nil {
+		return false, err
+	}
+	r, err := s.buildReturn(ctx, period, start, end)
+	if err != nil {
+		return false, err
+	}
+	saved, err := s.repo.SaveReturn(ctx, r)
+	if saved {
+		s.logger.Info("Complaints return generated", map[string]interface{}{"period": period, "complaints": r.Complaints})
+	}
+	return saved, err
+}
+
+// ListReturns returns the kept returns, latest first.
+func (s *Service) ListReturns(ctx context.Context) ([]domain.ComplaintReturn, error) {
+	return s.repo.ListReturns(ctx)
+}
+
+func (s *Service) buildReturn(ctx context.Context, period string, start, end time.Time) (*domain.ComplaintReturn, error) {
+	lines, err := s.repo.ReturnLines(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeReturn(&buf, s.cfg.InstitutionCode, period, end, lines); err != nil {
+		return nil, err
+	}
+	return &domain.ComplaintReturn{
+		Period:      period,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Complaints:  len(lines),
+		Content:     buf.Bytes(),
+		GeneratedAt: s.now().UTC(),
+	}, nil
+}
+
+// writeReturn writes lines as a return for the quarter ending at end. What
+// happened after end is left out, so a complaint resolved since is still
+// pending in it.
+func writeReturn(w io.Writer, institution, period string, end time.Time, lines []domain.ComplaintReturnLine) error {
+	const dateLayout = "2006-01-02"
+	cw := csv.NewWriter(w)
+	if err := cw.Write(returnHeader); err != nil {
+		return err
+	}
+	for _, l := range lines {
+		var amount, acknowledged, ackInSLA, resolved, days, resolvedInSLA, outcome, rootCause, redress string
+		if l.Amount != nil {
+			amount = l.Amount.StringFixed(2)
+		}
+		status := "pending"
+		if l.AcknowledgedAt != nil && l.AcknowledgedAt.Before(end) {
+			acknowledged = l.AcknowledgedAt.UTC().Format(dateLayout)
+			ackInSLA = yesNo(!l.AcknowledgedAt.After(l.AcknowledgeBy))
+		} else if end.After(l.AcknowledgeBy) {
+			ackInSLA = yesNo(false)
+		}
+		if l.ResolvedAt != nil && l.ResolvedAt.Before(end) {
+			status = "resolved"
+			resolved = l.ResolvedAt.UTC().Format(dateLayout)
+			days = strconv.Itoa(int((l.ResolvedAt.Sub(l.ReceivedAt) + 24*time.Hour - 1) / (24 * time.Hour)))
+			resolvedInSLA = yesNo(!l.ResolvedAt.After(l.ResolveBy))
+			if l.Outcome != nil {
+				outcome = string(*l.Outcome)
+			}
+			if l.RootCause != nil {
+				rootCause = string(*l.RootCause)
+			}
+			if l.RedressAmount != nil {
+				redress = l.RedressAmount.StringFixed(2)
+			}
+		} else if end.After(l.ResolveBy) {
+			resolvedInSLA = yesNo(false)
+		}
+		complainant := l.UserType
+		if complainant == "" {
+			complainant = "non_customer"
+		}
+		if err := cw.Write([]string{
+			institution, period, l.Reference, l.ReceivedAt.UTC().Format(dateLayout), string(l.Channel),
+			complainant, string(l.Category), l.TransactionReference, amount, l.Currency, status,
+			acknowledged, ackInSLA, resolved, days,
+			resolvedInSLA, outcome, rootCause, redress,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Y"
+	}
+	return "N"
+}
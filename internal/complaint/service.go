@@ -0,0 +1,466 @@
+// Package complaint keeps the complaints register: complaints about the
+// service, taken in from customers in the app and logged by staff for those
+// received by phone, email, agent or letter. Each is held to an
+// acknowledgement and a resolution deadline, resolved with an outcome and a
+// root cause, and reported to the Reserve Bank of Malawi every quarter.
+//
+// Complaints are distinct from disputes, which ask for a transaction to be
+// reversed; a complaint may be about anything, a transaction included.
+package complaint
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidComplaint = errors.New("invalid complaint")
+	ErrNotFound         = errors.New("complaint not found")
+	// ErrInvalidStatus is returned for a change the complaint's status does
+	// not allow, such as resolving one twice.
+	ErrInvalidStatus = errors.New("complaint status does not allow this")
+	ErrInvalidPeriod = errors.New("invalid reporting period")
+)
+
+// Repository persists the register.
+type Repository interface {
+	Create(ctx context.Context, c *domain.Complaint) error
+	Get(ctx context.Context, id uuid.UUID) (*domain.Complaint, error)
+	// List pages through complaints matching filter, newest first. Overdue
+	// is judged at now.
+	List(ctx context.Context, filter domain.ComplaintFilter, now time.Time, limit, offset int) ([]domain.Complaint, int, error)
+	// Update saves c if it still has status from, reporting false when it
+	// no longer had.
+	Update(ctx context.Context, c *domain.Complaint, from domain.ComplaintStatus) (bool, error)
+	// ReturnLines returns the complaints handled in [from, to): received
+	// before to and not resolved before from.
+	ReturnLines(ctx context.Context, from, to time.Time) ([]domain.ComplaintReturnLine, error)
+	// SaveReturn stores r, reporting false when its period already has one.
+	SaveReturn(ctx context.Context, r *domain.ComplaintReturn) (bool, error)
+	// GetReturn returns nil when the period has no return kept.
+	GetReturn(ctx context.Context, period string) (*domain.ComplaintReturn, error)
+	ListReturns(ctx context.Context) ([]domain.ComplaintReturn, error)
+}
+
+// Transactions looks up the transaction a complaint is about. FindByID
+// returns errors.ErrTransactionNotFound for one that does not exist.
+type Transactions interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
+}
+
+// Users looks up complainants. FindByID returns errors.ErrUserNotFound for
+// one that does not exist.
+type Users interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
+// Notification event types. Each carries the complaint's reference and a
+// message in its language.
+const (
+	EventComplaintReceived     = "COMPLAINT_RECEIVED"
+	EventComplaintAcknowledged = "COMPLAINT_ACKNOWLEDGED"
+	EventComplaintResolved     = "COMPLAINT_RESOLVED"
+)
+
+const (
+	defaultAcknowledgeWithin = 48 * time.Hour
+	defaultResolveWithin     = 30 * 24 * time.Hour
+	defaultReturnInterval    = time.Hour
+	maxSubject               = 255
+	maxDescription           = 5000
+	maxResolution            = 5000
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type Service struct {
+	repo         Repository
+	transactions Transactions
+	users        Users
+	notifier     Notifier
+	cfg          config.ComplaintConfig
+	logger       logger.Logger
+	now          func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, transactions Transactions, users Users, notifier Notifier, cfg config.ComplaintConfig, log logger.Logger) *Service {
+	if cfg.AcknowledgeWithin <= 0 {
+		cfg.AcknowledgeWithin = defaultAcknowledgeWithin
+	}
+	if cfg.ResolveWithin <= 0 {
+		cfg.ResolveWithin = defaultResolveWithin
+	}
+	if cfg.ReturnInterval <= 0 {
+		cfg.ReturnInterval = defaultReturnInterval
+	}
+	return &Service{
+		repo:         repo,
+		transactions: transactions,
+		users:        users,
+		notifier:     notifier,
+		cfg:          cfg,
+		logger:       log,
+		now:          time.Now,
+		stop:         make(chan struct{}),
+	}
+}
+
+// SubmitRequest is a complaint a customer makes in the app.
+type SubmitRequest struct {
+	Category domain.ComplaintCategory `json:"category"`
+	// Language defaults to English.
+	Language      string           `json:"language"`
+	Subject       string           `json:"subject"`
+	Description   string           `json:"description"`
+	TransactionID *uuid.UUID       `json:"transaction_id"`
+	Amount        *decimal.Decimal `json:"amount"`
+	Currency      string           `json:"currency"`
+}
+
+// Submit records a customer's complaint and confirms its receipt to them
+// in their language.
+func (s *Service) Submit(ctx context.Context, userID uuid.UUID, req *SubmitRequest) (*domain.Complaint, error) {
+	c := &domain.Complaint{
+		UserID:        &userID,
+		TransactionID: req.TransactionID,
+		Channel:       domain.ComplaintChannelApp,
+		Category:      req.Category,
+		Language:      req.Language,
+		Subject:       req.Subject,
+		Description:   req.Description,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+	}
+	if err := s.record(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LogRequest is a complaint staff record for someone, usually one received
+// outside the app. UserID is set for customers; people without an account
+// give their name and how to reach them.
+type LogRequest struct {
+	SubmitRequest
+	UserID             *uuid.UUID              `json:"user_id"`
+	ComplainantName    string                  `json:"complainant_name"`
+	ComplainantContact string                  `json:"complainant_contact"`
+	Channel            domain.ComplaintChannel `json:"channel"`
+	// ReceivedAt is when the complaint reached us, if before now.
+	ReceivedAt *time.Time `json:"received_at"`
+}
+
+// Log records a complaint taken by a member of staff.
+func (s *Service) Log(ctx context.Context, staffID uuid.UUID, req *LogRequest) (*domain.Complaint, error) {
+	if !req.Channel.IsValid() {
+		return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidComplaint, req.Channel)
+	}
+	c := &domain.Complaint{
+		UserID:             req.UserID,
+		TransactionID:      req.TransactionID,
+		ComplainantName:    strings.TrimSpace(req.ComplainantName),
+		ComplainantContact: strings.TrimSpace(req.ComplainantContact),
+		Channel:            req.Channel,
+		Category:           req.Category,
+		Language:           req.Language,
+		Subject:            req.Subject,
+		Description:        req.Description,
+		Amount:             req.Amount,
+		Currency:           req.Currency,
+		CreatedBy:          &staffID,
+	}
+	if req.ReceivedAt != nil {
+		c.ReceivedAt = req.ReceivedAt.UTC()
+	}
+	if c.UserID == nil && c.ComplainantName == "" {
+		return nil, fmt.Errorf("%w: a complaint needs user_id or complainant_name", ErrInvalidComplaint)
+	}
+	if utf8.RuneCountInString(c.ComplainantName) > 255 || utf8.RuneCountInString(c.ComplainantContact) > 255 {
+		return nil, fmt.Errorf("%w: complainant details must be at most 255 characters", ErrInvalidComplaint)
+	}
+	if c.UserID != nil {
+		if _, err := s.users.FindByID(ctx, *c.UserID); err != nil {
+			if errors.Is(err, pkgerrors.ErrUserNotFound) {
+				return nil, fmt.Errorf("%w: user_id is not a user", ErrInvalidComplaint)
+			}
+			return nil, err
+		}
+	}
+	if err := s.record(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// record validates c, sets its deadlines and saves it.
+func (s *Service) record(ctx context.Context, c *domain.Complaint) error {
+	now := s.now().UTC()
+	if c.ReceivedAt.IsZero() {
+		c.ReceivedAt = now
+	}
+	c.Language = strings.ToLower(strings.TrimSpace(c.Language))
+	if c.Language == "" {
+		c.Language = LanguageEnglish
+	}
+	c.Subject = strings.TrimSpace(c.Subject)
+	c.Description = strings.TrimSpace(c.Description)
+	c.Currency = strings.ToUpper(strings.TrimSpace(c.Currency))
+	switch {
+	case !c.Category.IsValid():
+		return fmt.Errorf("%w: unknown category %q", ErrInvalidComplaint, c.Category)
+	case !IsSupportedLanguage(c.Language):
+		return fmt.Errorf("%w: language must be one of %s", ErrInvalidComplaint, strings.Join(SupportedLanguages(), ", "))
+	case c.Subject == "" || utf8.RuneCountInString(c.Subject) > maxSubject:
+		return fmt.Errorf("%w: subject is required and at most %d characters", ErrInvalidComplaint, maxSubject)
+	case c.Description == "" || utf8.RuneCountInString(c.Description) > maxDescription:
+		return fmt.Errorf("%w: description is required and at most %d characters", ErrInvalidComplaint, maxDescription)
+	case c.ReceivedAt.After(now):
+		return fmt.Errorf("%w: received_at cannot be in the future", ErrInvalidComplaint)
+	case c.Amount != nil && !c.Amount.IsPositive():
+		return fmt.Errorf("%w: amount must be greater than zero", ErrInvalidComplaint)
+	case c.Amount != nil && !currencyCode.MatchString(c.Currency):
+		return fmt.Errorf("%w: an amount needs its currency", ErrInvalidComplaint)
+	}
+
+	if c.TransactionID != nil {
+		tx, err := s.transactions.FindByID(ctx, *c.TransactionID)
+		if errors.Is(err, pkgerrors.ErrTransactionNotFound) || (err == nil && !involves(tx, c.UserID)) {
+			return fmt.Errorf("%w: transaction_id is not one of the complainant's transactions", ErrInvalidComplaint)
+		}
+		if err != nil {
+			return err
+		}
+		if c.Amount == nil {
+			amount := tx.Amount
+			c.Amount, c.Currency = &amount, string(tx.Currency)
+		}
+	}
+
+	reference, err := newReference(c.ReceivedAt)
+	if err != nil {
+		return err
+	}
+	c.ID = uuid.New()
+	c.Reference = reference
+	c.Status = domain.ComplaintReceived
+	c.AcknowledgeBy = c.ReceivedAt.Add(s.cfg.AcknowledgeWithin)
+	c.ResolveBy = c.ReceivedAt.Add(s.cfg.ResolveWithin)
+	c.UpdatedAt = now
+	if err := s.repo.Create(ctx, c); err != nil {
+		return err
+	}
+	s.notify(ctx, c, EventComplaintReceived)
+	return nil
+}
+
+// involves reports whether tx is the complainant's. Complaints from people
+// without an account may name any transaction.
+func involves(tx *domain.Transaction, userID *uuid.UUID) bool {
+	if userID == nil {
+		return true
+	}
+	return tx.SenderID == *userID || tx.ReceiverID == *userID
+}
+
+// Get returns one of the user's complaints.
+func (s *Service) Get(ctx context.Context, userID, id uuid.UUID) (*domain.Complaint, error) {
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserID == nil || *c.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return c, nil
+}
+
+// List returns the user's complaints, newest first.
+func (s *Service) List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.Complaint, int, error) {
+	return s.repo.List(ctx, domain.ComplaintFilter{UserID: &userID}, s.now().UTC(), limit, offset)
+}
+
+// Find returns any complaint (staff).
+func (s *Service) Find(ctx context.Context, id uuid.UUID) (*domain.Complaint, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// Search pages through the register (staff).
+func (s *Service) Search(ctx context.Context, filter domain.ComplaintFilter, limit, offset int) ([]domain.Complaint, int, error) {
+	return s.repo.List(ctx, filter, s.now().UTC(), limit, offset)
+}
+
+// Acknowledge confirms to the complainant that their complaint is being
+// dealt with, by assignee or, when nil, by the member of staff acting.
+func (s *Service) Acknowledge(ctx context.Context, staffID, id uuid.UUID, assignee *uuid.UUID) (*domain.Complaint, error) {
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != domain.ComplaintReceived {
+		return nil, fmt.Errorf("%w: the complaint is already %s", ErrInvalidStatus, c.Status)
+	}
+	if assignee == nil {
+		assignee = &staffID
+	}
+	now := s.now().UTC()
+	c.Status = domain.ComplaintAcknowledged
+	c.AcknowledgedAt = &now
+	c.AssignedTo = assignee
+	c.UpdatedAt = now
+	if err := s.update(ctx, c, domain.ComplaintReceived); err != nil {
+		return nil, err
+	}
+	s.notify(ctx, c, EventComplaintAcknowledged)
+	return c, nil
+}
+
+// ResolveRequest closes a complaint.
+type ResolveRequest struct {
+	Outcome   domain.ComplaintOutcome   `json:"outcome"`
+	RootCause domain.ComplaintRootCause `json:"root_cause"`
+	// Resolution is what was done, as told to the complainant.
+	Resolution string `json:"resolution"`
+	// RedressAmount is what the complainant was paid or refunded, in the
+	// complaint's currency.
+	RedressAmount *decimal.Decimal `json:"redress_amount"`
+}
+
+// Resolve closes a complaint with its outcome and root cause, and tells the
+// complainant. A complaint not yet acknowledged is acknowledged with it.
+func (s *Service) Resolve(ctx context.Context, staffID, id uuid.UUID, req *ResolveRequest) (*domain.Complaint, error) {
+	resolution := strings.TrimSpace(req.Resolution)
+	switch {
+	case !req.Outcome.IsValid():
+		return nil, fmt.Errorf("%w: unknown outcome %q", ErrInvalidComplaint, req.Outcome)
+	case !req.RootCause.IsValid():
+		return nil, fmt.Errorf("%w: unknown root_cause %q", ErrInvalidComplaint, req.RootCause)
+	case resolution == "" || utf8.RuneCountInString(resolution) > maxResolution:
+		return nil, fmt.Errorf("%w: resolution is required and at most %d characters", ErrInvalidComplaint, maxResolution)
+	case req.RedressAmount != nil && req.RedressAmount.IsNegative():
+		return nil, fmt.Errorf("%w: redress_amount cannot be negative", ErrInvalidComplaint)
+	}
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	from := c.Status
+	if from == domain.ComplaintResolved {
+		return nil, fmt.Errorf("%w: the complaint is already resolved", ErrInvalidStatus)
+	}
+	if req.RedressAmount != nil && req.RedressAmount.IsPositive() && c.Currency == "" {
+		return nil, fmt.Errorf("%w: the complaint has no currency to pay redress in", ErrInvalidComplaint)
+	}
+	now := s.now().UTC()
+	if c.AcknowledgedAt == nil {
+		c.AcknowledgedAt = &now
+	}
+	if c.AssignedTo == nil {
+		c.AssignedTo = &staffID
+	}
+	c.Status = domain.ComplaintResolved
+	c.ResolvedAt = &now
+	c.Outcome = &req.Outcome
+	c.RootCause = &req.RootCause
+	c.Resolution = resolution
+	c.RedressAmount = req.RedressAmount
+	c.UpdatedAt = now
+	if err := s.update(ctx, c, from); err != nil {
+		return nil, err
+	}
+	s.notify(ctx, c, EventComplaintResolved)
+	return c, nil
+}
+
+func (s *Service) update(ctx context.Context, c *domain.Complaint, from domain.ComplaintStatus) error {
+	ok, err := s.repo.Update(ctx, c, from)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: it changed in the meantime", ErrInvalidStatus)
+	}
+	return nil
+}
+
+// notify tells a customer about their complaint in its language. People
+// without an account are answered by staff through the contact they gave.
+func (s *Service) notify(ctx context.Context, c *domain.Complaint, event string) {
+	if c.UserID == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"complaint_id": c.ID.String(),
+		"reference":    c.Reference,
+		"message":      message(c, event, s.cfg.AcknowledgeWithin),
+	}
+	if err := s.notifier.Notify(ctx, *c.UserID, event, data); err != nil {
+		s.logger.Warn("Failed to notify complainant", map[string]interface{}{"error": err.Error(), "complaint_id": c.ID, "event": event})
+	}
+}
+
+// newReference returns a reference such as CMP-20260302-9F3A1C for a
+// complaint received at t, to be quoted by the complainant.
+func newReference(t time.Time) (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "CMP-" + t.Format("20060102") + "-" + strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+// Start generates each quarter's return once the quarter has ended,
+// checking every configured interval until Stop.
+func (s *Service) Start() {
+	go func() {
+		ticker := time.NewTicker(s.cfg.ReturnInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.GenerateDueReturn(context.Background()); err != nil {
+					s.logger.Error("Failed to generate complaints return", map[string]interface{}{"error": err.Error()})
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Complaints return worker started", map[string]interface{}{"interval": s.cfg.ReturnInterval.String()})
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// SupportedLanguages lists the languages complaints are answered in.
+func SupportedLanguages() []string {
+	return []string{LanguageEnglish, LanguageChichewa}
+}
+
+func IsSupportedLanguage(lang string) bool {
+	_, ok := messages[lang]
+	return ok
+}
@@ -0,0 +1,479 @@
+package complaint
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, c *domain.Complaint) error {
+	return m.Called(ctx, c).Error(0)
+}
+
+func (m *MockRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Complaint, error) {
+	args := m.Called(ctx, id)
+	c, _ := args.Get(0).(*domain.Complaint)
+	if c == nil {
+		return nil, args.Error(1)
+	}
+	copied := *c
+	return &copied, args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, filter domain.ComplaintFilter, now time.Time, limit, offset int) ([]domain.Complaint, int, error) {
+	args := m.Called(ctx, filter, now, limit, offset)
+	return args.Get(0).([]domain.Complaint), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) Update(ctx context.Context, c *domain.Complaint, from domain.ComplaintStatus) (bool, error) {
+	args := m.Called(ctx, c, from)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ReturnLines(ctx context.Context, from, to time.Time) ([]domain.ComplaintReturnLine, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).([]domain.ComplaintReturnLine), args.Error(1)
+}
+
+func (m *MockRepository) SaveReturn(ctx context.Context, r *domain.ComplaintReturn) (bool, error) {
+	args := m.Called(ctx, r)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetReturn(ctx context.Context, period string) (*domain.ComplaintReturn, error) {
+	args := m.Called(ctx, period)
+	r, _ := args.Get(0).(*domain.ComplaintReturn)
+	return r, args.Error(1)
+}
+
+func (m *MockRepository) ListReturns(ctx context.Context) ([]domain.ComplaintReturn, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.ComplaintReturn), args.Error(1)
+}
+
+type MockTransactions struct {
+	mock.Mock
+}
+
+func (m *MockTransactions) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	args := m.Called(ctx, id)
+	tx, _ := args.Get(0).(*domain.Transaction)
+	return tx, args.Error(1)
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	u, _ := args.Get(0).(*domain.User)
+	return u, args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
+	return m.Called(ctx, userID, eventType, data).Error(0)
+}
+
+type mocks struct {
+	repo         *MockRepository
+	transactions *MockTransactions
+	users        *MockUsers
+	notifier     *MockNotifier
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.transactions.AssertExpectations(t)
+	m.users.AssertExpectations(t)
+	m.notifier.AssertExpectations(t)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *mocks) {
+	m := &mocks{new(MockRepository), new(MockTransactions), new(MockUsers), new(MockNotifier)}
+	s := NewService(m.repo, m.transactions, m.users, m.notifier,
+		config.ComplaintConfig{AcknowledgeWithin: 48 * time.Hour, ResolveWithin: 30 * 24 * time.Hour, InstitutionCode: "KYD01"},
+		logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, m
+}
+
+// saying matches notification data whose message contains part.
+func saying(part string) interface{} {
+	return mock.MatchedBy(func(data map[string]interface{}) bool {
+		msg, _ := data["message"].(string)
+		return bytes.Contains([]byte(msg), []byte(part))
+	})
+}
+
+func TestSubmit(t *testing.T) {
+	customer := uuid.New()
+	tx := &domain.Transaction{ID: uuid.New(), SenderID: customer, ReceiverID: uuid.New(), Amount: decimal.NewFromInt(15000), Currency: domain.MWK}
+	now := start
+	s, m := newService(&now)
+	m.transactions.On("FindByID", ctx, tx.ID).Return(tx, nil).Once()
+	var reference string
+	m.repo.On("Create", ctx, mock.MatchedBy(func(c *domain.Complaint) bool {
+		reference = c.Reference
+		return c.Status == domain.ComplaintReceived && *c.UserID == customer
+	})).Return(nil).Once()
+	m.notifier.On("Notify", ctx, customer, EventComplaintReceived, mock.MatchedBy(func(data map[string]interface{}) bool {
+		return data["message"] == "We have received your complaint "+reference+". We will respond within 2 day(s) and aim to resolve it by 1 April 2026."
+	})).Return(nil).Once()
+
+	c, err := s.Submit(ctx, customer, &SubmitRequest{
+		Category:      domain.ComplaintDelayedTransaction,
+		Subject:       " Money not received ",
+		Description:   "My brother has not received the money I sent.",
+		TransactionID: &tx.ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.ComplaintChannelApp, c.Channel)
+	assert.Equal(t, LanguageEnglish, c.Language)
+	assert.Equal(t, "Money not received", c.Subject)
+	assert.Regexp(t, `^CMP-20260302-[0-9A-F]{6}$`, c.Reference)
+	assert.Equal(t, now.Add(48*time.Hour), c.AcknowledgeBy)
+	assert.Equal(t, now.Add(30*24*time.Hour), c.ResolveBy)
+	require.NotNil(t, c.Amount, "taken from the transaction")
+	assert.Equal(t, "15000", c.Amount.String())
+	assert.Equal(t, "MWK", c.Currency)
+	m.assert(t)
+}
+
+func TestSubmitValidates(t *testing.T) {
+	customer := uuid.New()
+	mine := &domain.Transaction{ID: uuid.New(), SenderID: customer, ReceiverID: uuid.New()}
+	theirs := &domain.Transaction{ID: uuid.New(), SenderID: uuid.New(), ReceiverID: uuid.New()}
+	unknown := uuid.New()
+	amount := decimal.NewFromInt(100)
+
+	tests := []struct {
+		name string
+		req  SubmitRequest
+	}{
+		{"no category", SubmitRequest{Subject: "s", Description: "d"}},
+		{"unknown language", SubmitRequest{Category: domain.ComplaintOther, Language: "fr", Subject: "s", Description: "d"}},
+		{"no description", SubmitRequest{Category: domain.ComplaintOther, Subject: "s"}},
+		{"amount, no currency", SubmitRequest{Category: domain.ComplaintCharges, Subject: "s", Description: "d", Amount: &amount}},
+		{"unknown transaction", SubmitRequest{Category: domain.ComplaintOther, Subject: "s", Description: "d", TransactionID: &unknown}},
+		{"another customer's transaction", SubmitRequest{Category: domain.ComplaintOther, Subject: "s", Description: "d", TransactionID: &theirs.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			s, m := newService(&now)
+			m.transactions.On("FindByID", ctx, mine.ID).Return(mine, nil).Maybe()
+			m.transactions.On("FindByID", ctx, theirs.ID).Return(theirs, nil).Maybe()
+			m.transactions.On("FindByID", ctx, unknown).Return(nil, pkgerrors.ErrTransactionNotFound).Maybe()
+
+			_, err := s.Submit(ctx, customer, &tt.req)
+			assert.ErrorIs(t, err, ErrInvalidComplaint)
+			m.repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetOnlyTheirOwn(t *testing.T) {
+	customer := uuid.New()
+	c := &domain.Complaint{ID: uuid.New(), UserID: &customer}
+	logged := &domain.Complaint{ID: uuid.New(), ComplainantName: "Grace Phiri"}
+	now := start
+	s, m := newService(&now)
+	m.repo.On("Get", ctx, c.ID).Return(c, nil)
+	m.repo.On("Get", ctx, logged.ID).Return(logged, nil)
+
+	_, err := s.Get(ctx, customer, c.ID)
+	require.NoError(t, err)
+	_, err = s.Get(ctx, uuid.New(), c.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Get(ctx, customer, logged.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLog(t *testing.T) {
+	staff, customer, unknown := uuid.New(), uuid.New(), uuid.New()
+	now := start
+	receivedAt := now.Add(-3 * time.Hour)
+	s, m := newService(&now)
+	m.repo.On("Create", ctx, mock.Anything).Return(nil).Once()
+
+	req := &LogRequest{
+		SubmitRequest:   SubmitRequest{Category: domain.ComplaintService, Language: "NY", Subject: "Agent was rude", Description: "Wothandizira anali wamwano."},
+		ComplainantName: "Grace Phiri",
+		Channel:         domain.ComplaintChannelPhone,
+		ReceivedAt:      &receivedAt,
+	}
+	c, err := s.Log(ctx, staff, req)
+	require.NoError(t, err)
+	assert.Nil(t, c.UserID)
+	assert.Equal(t, LanguageChichewa, c.Language)
+	assert.Equal(t, receivedAt, c.ReceivedAt)
+	assert.Equal(t, receivedAt.Add(48*time.Hour), c.AcknowledgeBy, "deadlines run from receipt")
+	assert.Equal(t, staff, *c.CreatedBy)
+	m.assert(t)
+	m.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	m.users.On("FindByID", ctx, unknown).Return(nil, pkgerrors.ErrUserNotFound)
+	for name, edit := range map[string]func(r *LogRequest){
+		"nobody to answer": func(r *LogRequest) { r.ComplainantName = "" },
+		"unknown user":     func(r *LogRequest) { r.UserID = &unknown },
+		"unknown channel":  func(r *LogRequest) { r.UserID, r.Channel = &customer, "fax" },
+		"received later":   func(r *LogRequest) { later := now.Add(time.Hour); r.ReceivedAt = &later },
+	} {
+		bad := *req
+		edit(&bad)
+		_, err := s.Log(ctx, staff, &bad)
+		assert.ErrorIs(t, err, ErrInvalidComplaint, name)
+	}
+	m.repo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestAcknowledge(t *testing.T) {
+	staff, customer := uuid.New(), uuid.New()
+	received := &domain.Complaint{
+		ID: uuid.New(), Reference: "CMP-1", UserID: &customer, Language: LanguageChichewa,
+		Status: domain.ComplaintReceived, ResolveBy: start.Add(30 * 24 * time.Hour),
+	}
+	now := start.Add(time.Hour)
+	s, m := newService(&now)
+	m.repo.On("Get", ctx, received.ID).Return(received, nil).Once()
+	m.repo.On("Update", ctx, mock.MatchedBy(func(c *domain.Complaint) bool {
+		return c.Status == domain.ComplaintAcknowledged && *c.AssignedTo == staff && c.AcknowledgedAt.Equal(now)
+	}), domain.ComplaintReceived).Return(true, nil).Once()
+	// Answered in Chichewa
+	m.notifier.On("Notify", ctx, customer, EventComplaintAcknowledged, saying("likuunikidwa")).Return(nil).Once()
+
+	acked, err := s.Acknowledge(ctx, staff, received.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ComplaintAcknowledged, acked.Status)
+	m.assert(t)
+
+	t.Run("already acknowledged", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("Get", ctx, acked.ID).Return(acked, nil).Once()
+		_, err := s.Acknowledge(ctx, staff, acked.ID, nil)
+		assert.ErrorIs(t, err, ErrInvalidStatus)
+	})
+
+	t.Run("changed in the meantime", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("Get", ctx, received.ID).Return(received, nil).Once()
+		m.repo.On("Update", ctx, mock.Anything, domain.ComplaintReceived).Return(false, nil).Once()
+		_, err := s.Acknowledge(ctx, staff, received.ID, nil)
+		assert.ErrorIs(t, err, ErrInvalidStatus)
+		m.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestResolve(t *testing.T) {
+	staff, customer := uuid.New(), uuid.New()
+	acknowledgedAt := start
+	acked := &domain.Complaint{
+		ID: uuid.New(), Reference: "CMP-1", UserID: &customer, Language: LanguageChichewa,
+		Status: domain.ComplaintAcknowledged, AcknowledgedAt: &acknowledgedAt, AssignedTo: &staff,
+	}
+	now := start.Add(time.Hour)
+	s, m := newService(&now)
+	m.repo.On("Get", ctx, acked.ID).Return(acked, nil)
+	redress := decimal.NewFromInt(500)
+
+	_, err := s.Resolve(ctx, staff, acked.ID, &ResolveRequest{Outcome: domain.ComplaintUpheld, RootCause: "gremlins", Resolution: "Refunded."})
+	assert.ErrorIs(t, err, ErrInvalidComplaint)
+	_, err = s.Resolve(ctx, staff, acked.ID, &ResolveRequest{Outcome: domain.ComplaintUpheld, RootCause: domain.RootCauseSystemError, Resolution: "Refunded.", RedressAmount: &redress})
+	assert.ErrorIs(t, err, ErrInvalidComplaint, "redress without a currency")
+
+	m.repo.On("Update", ctx, mock.MatchedBy(func(c *domain.Complaint) bool {
+		return c.Status == domain.ComplaintResolved && *c.RootCause == domain.RootCauseSystemError &&
+			c.ResolvedAt.Equal(now) && c.AcknowledgedAt.Equal(acknowledgedAt)
+	}), domain.ComplaintAcknowledged).Return(true, nil).Once()
+	m.notifier.On("Notify", ctx, customer, EventComplaintResolved, saying("Tabweza ndalama zanu.")).Return(nil).Once()
+
+	resolved, err := s.Resolve(ctx, staff, acked.ID, &ResolveRequest{Outcome: domain.ComplaintUpheld, RootCause: domain.RootCauseSystemError, Resolution: "Tabweza ndalama zanu."})
+	require.NoError(t, err)
+	assert.Equal(t, domain.ComplaintResolved, resolved.Status)
+	m.assert(t)
+
+	t.Run("already resolved", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("Get", ctx, resolved.ID).Return(resolved, nil).Once()
+		_, err := s.Resolve(ctx, staff, resolved.ID, &ResolveRequest{Outcome: domain.ComplaintUpheld, RootCause: domain.RootCauseSystemError, Resolution: "Again."})
+		assert.ErrorIs(t, err, ErrInvalidStatus)
+	})
+
+	t.Run("acknowledged with it", func(t *testing.T) {
+		received := &domain.Complaint{ID: uuid.New(), UserID: &customer, Language: LanguageEnglish, Status: domain.ComplaintReceived}
+		s, m := newService(&now)
+		m.repo.On("Get", ctx, received.ID).Return(received, nil).Once()
+		m.repo.On("Update", ctx, mock.MatchedBy(func(c *domain.Complaint) bool {
+			return c.AcknowledgedAt.Equal(now) && *c.AssignedTo == staff
+		}), domain.ComplaintReceived).Return(true, nil).Once()
+		m.notifier.On("Notify", ctx, customer, EventComplaintResolved, mock.Anything).Return(nil).Once()
+
+		_, err := s.Resolve(ctx, staff, received.ID, &ResolveRequest{Outcome: domain.ComplaintNotUpheld, RootCause: domain.RootCauseCustomerError, Resolution: "No fault found."})
+		require.NoError(t, err)
+		m.assert(t)
+	})
+}
+
+func TestParsePeriod(t *testing.T) {
+	start, end, err := ParsePeriod("2026-Q3")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), end)
+	for _, bad := range []string{"2026-Q5", "2026-Q0", "2026Q1", "2026-Q12", "26-Q1"} {
+		_, _, err := ParsePeriod(bad)
+		assert.ErrorIs(t, err, ErrInvalidPeriod, bad)
+	}
+	assert.Equal(t, "2026-Q1", periodOf(time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)))
+}
+
+func TestGenerateDueReturn(t *testing.T) {
+	q1, q2 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	ptr := func(t time.Time) *time.Time { return &t }
+	outcome, cause := domain.ComplaintPartiallyUpheld, domain.RootCauseThirdParty
+	amount := decimal.NewFromInt(15000)
+	// Resolved within the quarter, late, having never been acknowledged
+	late := domain.ComplaintReturnLine{
+		Complaint: domain.Complaint{
+			Reference: "CMP-LATE", Channel: domain.ComplaintChannelApp, Category: domain.ComplaintFailedTransaction,
+			Amount: &amount, Currency: "MWK",
+			ReceivedAt: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), AcknowledgeBy: time.Date(2026, 1, 7, 9, 0, 0, 0, time.UTC),
+			ResolveBy: time.Date(2026, 2, 4, 9, 0, 0, 0, time.UTC), ResolvedAt: ptr(time.Date(2026, 2, 20, 9, 0, 0, 0, time.UTC)),
+			AcknowledgedAt: ptr(time.Date(2026, 2, 20, 9, 0, 0, 0, time.UTC)), Outcome: &outcome, RootCause: &cause,
+		},
+		UserType: "individual",
+	}
+	// Received at the end of the quarter and acknowledged after it
+	open := domain.ComplaintReturnLine{
+		Complaint: domain.Complaint{
+			Reference: "CMP-OPEN", Channel: domain.ComplaintChannelPhone, Category: domain.ComplaintOther,
+			ReceivedAt: time.Date(2026, 3, 30, 9, 0, 0, 0, time.UTC), AcknowledgeBy: time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC),
+			ResolveBy: time.Date(2026, 4, 29, 9, 0, 0, 0, time.UTC), AcknowledgedAt: ptr(time.Date(2026, 4, 2, 9, 0, 0, 0, time.UTC)),
+		},
+	}
+
+	now := time.Date(2026, 4, 2, 9, 0, 0, 0, time.UTC)
+	s, m := newService(&now)
+	m.repo.On("GetReturn", ctx, "2026-Q1").Return(nil, nil).Once()
+	m.repo.On("ReturnLines", ctx, q1, q2).Return([]domain.ComplaintReturnLine{late, open}, nil).Once()
+	var saved *domain.ComplaintReturn
+	m.repo.On("SaveReturn", ctx, mock.MatchedBy(func(r *domain.ComplaintReturn) bool {
+		saved = r
+		return r.Period == "2026-Q1" && r.Complaints == 2 && r.GeneratedAt.Equal(now)
+	})).Return(true, nil).Once()
+
+	generated, err := s.GenerateDueReturn(ctx)
+	require.NoError(t, err)
+	assert.True(t, generated)
+	m.assert(t)
+
+	rows, err := csv.NewReader(bytes.NewReader(saved.Content)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, returnHeader, rows[0])
+	byRef := map[string]map[string]string{}
+	for _, row := range rows[1:] {
+		fields := map[string]string{}
+		for i, name := range returnHeader {
+			fields[name] = row[i]
+		}
+		byRef[fields["complaint_reference"]] = fields
+	}
+
+	resolvedRow := byRef[late.Reference]
+	assert.Equal(t, "KYD01", resolvedRow["institution_code"])
+	assert.Equal(t, "2026-Q1", resolvedRow["reporting_period"])
+	assert.Equal(t, "resolved", resolvedRow["status"])
+	assert.Equal(t, "2026-01-05", resolvedRow["date_received"])
+	assert.Equal(t, "2026-02-20", resolvedRow["date_resolved"])
+	assert.Equal(t, "46", resolvedRow["days_to_resolve"])
+	assert.Equal(t, "N", resolvedRow["resolved_within_sla"])
+	assert.Equal(t, "N", resolvedRow["acknowledged_within_sla"], "only acknowledged by being resolved")
+	assert.Equal(t, "partially_upheld", resolvedRow["outcome"])
+	assert.Equal(t, "third_party", resolvedRow["root_cause"])
+	assert.Equal(t, "15000.00", resolvedRow["amount"])
+	assert.Equal(t, "individual", resolvedRow["complainant_type"])
+
+	openRow := byRef[open.Reference]
+	assert.Equal(t, "pending", openRow["status"])
+	assert.Empty(t, openRow["date_acknowledged"], "acknowledged after the quarter ended")
+	assert.Empty(t, openRow["acknowledged_within_sla"], "not yet due at the quarter's end")
+	assert.Equal(t, "non_customer", openRow["complainant_type"])
+
+	t.Run("once per quarter", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetReturn", ctx, "2026-Q1").Return(saved, nil).Once()
+		generated, err := s.GenerateDueReturn(ctx)
+		require.NoError(t, err)
+		assert.False(t, generated)
+		m.repo.AssertNotCalled(t, "ReturnLines", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestReturn(t *testing.T) {
+	now := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+	kept := &domain.ComplaintReturn{Period: "2026-Q1", Content: []byte("filed")}
+
+	t.Run("not started", func(t *testing.T) {
+		s, _ := newService(&now)
+		_, err := s.Return(ctx, "2026-Q3")
+		assert.ErrorIs(t, err, ErrInvalidPeriod)
+	})
+
+	t.Run("the one filed", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetReturn", ctx, "2026-Q1").Return(kept, nil).Once()
+		r, err := s.Return(ctx, "2026-Q1")
+		require.NoError(t, err)
+		assert.Equal(t, kept, r)
+		m.repo.AssertNotCalled(t, "ReturnLines", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("current quarter is not kept", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetReturn", ctx, "2026-Q2").Return(nil, nil).Once()
+		m.repo.On("ReturnLines", ctx, mock.Anything, mock.Anything).Return([]domain.ComplaintReturnLine{}, nil).Once()
+		r, err := s.Return(ctx, "2026-Q2")
+		require.NoError(t, err)
+		assert.Zero(t, r.Complaints)
+		m.repo.AssertNotCalled(t, "SaveReturn", mock.Anything, mock.Anything)
+	})
+
+	t.Run("generated elsewhere meanwhile", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetReturn", ctx, "2026-Q1").Return(nil, nil).Once()
+		m.repo.On("ReturnLines", ctx, mock.Anything, mock.Anything).Return([]domain.ComplaintReturnLine{}, nil).Once()
+		m.repo.On("SaveReturn", ctx, mock.Anything).Return(false, nil).Once()
+		m.repo.On("GetReturn", ctx, "2026-Q1").Return(kept, nil).Once()
+		r, err := s.Return(ctx, "2026-Q1")
+		require.NoError(t, err)
+		assert.Equal(t, kept, r)
+		m.assert(t)
+	})
+}
@@ -0,0 +1,371 @@
+package compliance
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by the manual review queue.
+var (
+	ErrInvalidDecision = errors.New("decision must be approve, reject or request_info")
+	ErrNotesRequired   = errors.New("notes are required to reject or request more information")
+	ErrNothingToReview = errors.New("kyc profile has no documents in review")
+	ErrInvalidQueue    = errors.New("queue must be review or verification")
+	ErrInvalidLink     = errors.New("kyc document link is invalid or has expired")
+	errNoReviewQueue   = errors.New("kyc review queue is not configured")
+	errNoDocumentLinks = errors.New("kyc document links are not configured")
+)
+
+// defaultLinkTTL is how long document links last unless configured.
+const defaultLinkTTL = 5 * time.Minute
+
+// Review queues.
+const (
+	// QueueReview holds profiles whose documents have all been scanned
+	// and wait for a reviewer's decision.
+	QueueReview = "review"
+	// QueueVerification holds profiles with documents still waiting for,
+	// or stuck in, the virus scan.
+	QueueVerification = "verification"
+)
+
+// ReviewRepository finds profiles waiting for review and keeps reviewers'
+// decisions.
+type ReviewRepository interface {
+	FindPendingReview(ctx context.Context, limit, offset int) ([]domain.KYCQueueEntry, int, error)
+	FindPendingVerification(ctx context.Context, limit, offset int) ([]domain.KYCQueueEntry, int, error)
+	CreateReview(ctx context.Context, rv *domain.KYCReview) error
+	ListReviews(ctx context.Context, userID uuid.UUID) ([]domain.KYCReview, error)
+}
+
+// WithReviewQueue lets admins work through profiles waiting for manual
+// review and records their decisions in repo.
+func (s *Service) WithReviewQueue(repo ReviewRepository) *Service {
+	s.reviews = repo
+	return s
+}
+
+// WithDocumentLinks lets reviewers open document files through links
+// signed with secret that expire after ttl.
+func (s *Service) WithDocumentLinks(files DocumentFiles, secret string, ttl time.Duration) *Service {
+	if ttl <= 0 {
+		ttl = defaultLinkTTL
+	}
+	s.linkFiles = files
+	s.linkSecret = []byte(secret)
+	s.linkTTL = ttl
+	return s
+}
+
+// QueueItem is a profile waiting in a review queue.
+type QueueItem struct {
+	domain.KYCQueueEntry
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// ListQueue returns the profiles waiting in queue, longest waiting first.
+func (s *Service) ListQueue(ctx context.Context, queue string, limit, offset int) ([]QueueItem, int, error) {
+	if s.reviews == nil {
+		return nil, 0, errNoReviewQueue
+	}
+	var entries []domain.KYCQueueEntry
+	var total int
+	var err error
+	switch queue {
+	case QueueReview, "":
+		entries, total, err = s.reviews.FindPendingReview(ctx, limit, offset)
+	case QueueVerification:
+		entries, total, err = s.reviews.FindPendingVerification(ctx, limit, offset)
+	default:
+		return nil, 0, ErrInvalidQueue
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	items := make([]QueueItem, len(entries))
+	for i, e := range entries {
+		items[i].KYCQueueEntry = e
+		if u, err := s.userProvider.FindByID(ctx, e.UserID); err == nil {
+			items[i].Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+			items[i].Email = u.Email
+		}
+	}
+	return items, total, nil
+}
+
+// DocumentLink is a short-lived signed link to one of a document's files.
+type DocumentLink struct {
+	Side      string    `json:"side"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ReviewDocument is a current document as a reviewer sees it. Files is
+// empty for documents that have not passed the virus scan.
+type ReviewDocument struct {
+	DocumentProgress
+	DocumentNumber  *string                `json:"document_number,omitempty"`
+	ExpiryDate      *time.Time             `json:"expiry_date,omitempty"`
+	VirusScanStatus domain.VirusScanStatus `json:"virus_scan_status"`
+	Files           []DocumentLink         `json:"files"`
+}
+
+// Profile is everything a reviewer needs to decide a KYC application.
+type Profile struct {
+	UserID      uuid.UUID            `json:"user_id"`
+	Name        string               `json:"name"`
+	Email       string               `json:"email"`
+	Phone       string               `json:"phone"`
+	CountryCode string               `json:"country_code"`
+	DateOfBirth *time.Time           `json:"date_of_birth,omitempty"`
+	KYCStatus   domain.KYCStatus     `json:"kyc_status"`
+	KYCLevel    int                  `json:"kyc_level"`
+	Documents   []ReviewDocument     `json:"documents"`
+	Screening   *domain.AMLScreening `json:"screening,omitempty"`
+	Reviews     []domain.KYCReview   `json:"reviews"`
+}
+
+// GetProfile returns a user's KYC profile for review, with signed links to
+// the files of its current documents. The viewing is audited.
+func (s *Service) GetProfile(ctx context.Context, userID, viewerID uuid.UUID) (*Profile, error) {
+	if s.reviews == nil {
+		return nil, errNoReviewQueue
+	}
+	u, err := s.userProvider.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := s.reviews.ListReviews(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Profile{
+		UserID:      u.ID,
+		Name:        strings.TrimSpace(u.FirstName + " " + u.LastName),
+		Email:       u.Email,
+		Phone:       u.Phone,
+		CountryCode: u.CountryCode,
+		DateOfBirth: u.DateOfBirth,
+		KYCStatus:   u.KYCStatus,
+		KYCLevel:    u.KYCLevel,
+		Documents:   []ReviewDocument{},
+		Reviews:     reviews,
+	}
+	byID := make(map[uuid.UUID]*domain.KYCDocument, len(docs))
+	for i := range docs {
+		byID[docs[i].ID] = &docs[i]
+	}
+	for _, progress := range documentProgress(docs) {
+		d := byID[progress.ID]
+		p.Documents = append(p.Documents, ReviewDocument{
+			DocumentProgress: progress,
+			DocumentNumber:   d.DocumentNumber,
+			ExpiryDate:       d.ExpiryDate,
+			VirusScanStatus:  d.VirusScanStatus,
+			Files:            s.documentLinks(d),
+		})
+	}
+	if s.screenings != nil {
+		p.Screening, _ = s.screenings.LatestScreening(ctx, userID)
+	}
+
+	if s.auditRepo != nil {
+		_ = s.auditRepo.Create(ctx, &domain.AuditLog{
+			ID:         uuid.New(),
+			Action:     "kyc_profile_viewed",
+			Resource:   "users",
+			ResourceID: userID.String(),
+			UserID:     &viewerID,
+			Status:     "success",
+			CreatedAt:  time.Now(),
+			Metadata:   domain.Metadata{"documents": len(p.Documents)},
+		})
+	}
+	return p, nil
+}
+
+// documentFiles maps the sides of a document to its file URLs.
+func documentFiles(doc *domain.KYCDocument) map[string]*string {
+	return map[string]*string{
+		"front":  doc.FrontImageURL,
+		"back":   doc.BackImageURL,
+		"selfie": doc.SelfieImageURL,
+	}
+}
+
+// documentLinks signs a link to each of doc's files, if it may be opened.
+func (s *Service) documentLinks(doc *domain.KYCDocument) []DocumentLink {
+	links := []DocumentLink{}
+	if s.linkFiles == nil || !doc.ScanCleared() {
+		return links
+	}
+	expires := time.Now().Add(s.linkTTL)
+	for _, side := range []string{"front", "back", "selfie"} {
+		if u := documentFiles(doc)[side]; u == nil || *u == "" {
+			continue
+		}
+		q := url.Values{}
+		q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+		q.Set("signature", s.signLink(doc.ID, side, expires.Unix()))
+		links = append(links, DocumentLink{
+			Side:      side,
+			URL:       "/api/v1/kyc/documents/" + doc.ID.String() + "/files/" + side + "?" + q.Encode(),
+			ExpiresAt: expires,
+		})
+	}
+	return links
+}
+
+func (s *Service) signLink(docID uuid.UUID, side string, expires int64) string {
+	mac := hmac.New(sha256.New, s.linkSecret)
+	fmt.Fprintf(mac, "kyc|%s|%s|%d", docID, side, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// OpenDocumentFile verifies a signed document link and opens the file it
+// points at. It returns the file's URL, whose extension gives its type.
+// The caller must close the file.
+func (s *Service) OpenDocumentFile(ctx context.Context, docID uuid.UUID, side, expires, signature string) (io.ReadCloser, string, error) {
+	if s.linkFiles == nil {
+		return nil, "", errNoDocumentLinks
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return nil, "", ErrInvalidLink
+	}
+	if !hmac.Equal([]byte(s.signLink(docID, side, exp)), []byte(signature)) {
+		return nil, "", ErrInvalidLink
+	}
+	doc, err := s.repo.GetByID(ctx, docID)
+	if err != nil {
+		return nil, "", ErrDocumentNotFound
+	}
+	// A link signed before the document was quarantined stops working
+	if !doc.ScanCleared() {
+		return nil, "", ErrDocumentNotScanned
+	}
+	u := documentFiles(doc)[side]
+	if u == nil || *u == "" {
+		return nil, "", ErrDocumentNotFound
+	}
+	f, err := s.linkFiles.Open(ctx, *u)
+	if err != nil {
+		return nil, "", ErrDocumentNotFound
+	}
+	return f, *u, nil
+}
+
+// DecideProfile records a reviewer's decision on a profile in the review
+// queue. Approving and rejecting decide the application; requesting more
+// information sends its undecided documents back to the user, who can
+// upload them again. Rejections and requests need notes for the user.
+func (s *Service) DecideProfile(ctx context.Context, userID, reviewerID uuid.UUID, decision domain.KYCDecision, notes string) (*domain.KYCReview, error) {
+	if s.reviews == nil {
+		return nil, errNoReviewQueue
+	}
+	notes = strings.TrimSpace(notes)
+	switch decision {
+	case domain.KYCDecisionApprove:
+	case domain.KYCDecisionReject, domain.KYCDecisionRequestInfo:
+		if notes == "" {
+			return nil, ErrNotesRequired
+		}
+	default:
+		return nil, ErrInvalidDecision
+	}
+
+	docs, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var open []domain.KYCDocument
+	for _, d := range docs {
+		if d.SupersededAt == nil && d.ReviewStatus == domain.KYCDocumentInReview {
+			open = append(open, d)
+		}
+	}
+	if len(open) == 0 {
+		return nil, ErrNothingToReview
+	}
+
+	switch decision {
+	case domain.KYCDecisionApprove:
+		err = s.ReviewApplication(ctx, userID, string(domain.KYCStatusVerified), notes, reviewerID)
+	case domain.KYCDecisionReject:
+		err = s.ReviewApplication(ctx, userID, string(domain.KYCStatusRejected), notes, reviewerID)
+	case domain.KYCDecisionRequestInfo:
+		err = s.requestInfo(ctx, userID, open, notes, reviewerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &domain.KYCReview{
+		ID:         uuid.New(),
+		UserID:     userID,
+		ReviewerID: reviewerID,
+		Decision:   decision,
+		ReviewedAt: time.Now(),
+	}
+	if notes != "" {
+		rv.Notes = &notes
+	}
+	if err := s.reviews.CreateReview(ctx, rv); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// requestInfo sends docs back to the user with the reviewer's notes. The
+// application stays pending until they upload replacements.
+func (s *Service) requestInfo(ctx context.Context, userID uuid.UUID, docs []domain.KYCDocument, notes string, reviewerID uuid.UUID) error {
+	ids := make([]string, len(docs))
+	for i, d := range docs {
+		if err := s.repo.UpdateStatus(ctx, d.ID, string(domain.KYCStatusPending), &notes, &reviewerID); err != nil {
+			return err
+		}
+		if err := s.repo.SetReviewStatus(ctx, d.ID, domain.KYCDocumentInfoRequested, &notes); err != nil {
+			return err
+		}
+		ids[i] = d.ID.String()
+	}
+	if err := s.userProvider.UpdateKYCStatus(ctx, userID, domain.KYCStatusPending); err != nil {
+		return errors.Wrap(err, "failed to update user kyc status")
+	}
+
+	if s.auditRepo != nil {
+		_ = s.auditRepo.Create(ctx, &domain.AuditLog{
+			ID:         uuid.New(),
+			Action:     "kyc_info_requested",
+			Resource:   "users",
+			ResourceID: userID.String(),
+			UserID:     &reviewerID,
+			Status:     "success",
+			CreatedAt:  time.Now(),
+			Metadata: domain.Metadata{
+				"documents": ids,
+				"notes":     notes,
+			},
+		})
+	}
+	return nil
+}
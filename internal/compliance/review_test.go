@@ -0,0 +1,161 @@
+package compliance
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockReviews struct {
+	mock.Mock
+}
+
+func (m *MockReviews) FindPendingReview(ctx context.Context, limit, offset int) ([]domain.KYCQueueEntry, int, error) {
+	args := m.Called(ctx, limit, offset)
+	entries, _ := args.Get(0).([]domain.KYCQueueEntry)
+	return entries, args.Int(1), args.Error(2)
+}
+
+func (m *MockReviews) FindPendingVerification(ctx context.Context, limit, offset int) ([]domain.KYCQueueEntry, int, error) {
+	args := m.Called(ctx, limit, offset)
+	entries, _ := args.Get(0).([]domain.KYCQueueEntry)
+	return entries, args.Int(1), args.Error(2)
+}
+
+func (m *MockReviews) CreateReview(ctx context.Context, rv *domain.KYCReview) error {
+	return m.Called(ctx, rv).Error(0)
+}
+
+func (m *MockReviews) ListReviews(ctx context.Context, userID uuid.UUID) ([]domain.KYCReview, error) {
+	args := m.Called(ctx, userID)
+	reviews, _ := args.Get(0).([]domain.KYCReview)
+	return reviews, args.Error(1)
+}
+
+func TestDecideProfile_RequestInfoThenApprove(t *testing.T) {
+	s, m := newService()
+	reviews := &MockReviews{}
+	s.WithReviewQueue(reviews)
+	user := &domain.User{ID: uuid.New(), KYCStatus: domain.KYCStatusPending}
+	doc := passport(user.ID, domain.KYCDocumentInReview)
+
+	_, err := s.DecideProfile(ctx, user.ID, reviewer, domain.KYCDecisionRequestInfo, "  ")
+	assert.ErrorIs(t, err, ErrNotesRequired)
+	_, err = s.DecideProfile(ctx, user.ID, reviewer, "escalate", "")
+	assert.ErrorIs(t, err, ErrInvalidDecision)
+
+	notes := "passport photo page is cut off"
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(doc), nil).Once()
+	m.repo.On("UpdateStatus", ctx, doc.ID, string(domain.KYCStatusPending), &notes, &reviewer).Return(nil).Once()
+	m.repo.On("SetReviewStatus", ctx, doc.ID, domain.KYCDocumentInfoRequested, &notes).Return(nil).Once()
+	m.users.On("UpdateKYCStatus", ctx, user.ID, domain.KYCStatusPending).Return(nil).Once()
+	var requested *domain.KYCReview
+	reviews.On("CreateReview", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		requested = args.Get(1).(*domain.KYCReview)
+	}).Return(nil).Once()
+	rv, err := s.DecideProfile(ctx, user.ID, reviewer, domain.KYCDecisionRequestInfo, notes+" ")
+	require.NoError(t, err)
+	assert.Same(t, requested, rv)
+	assert.Equal(t, reviewer, rv.ReviewerID)
+	assert.Equal(t, notes, *rv.Notes)
+	assert.False(t, rv.ReviewedAt.IsZero())
+	m.assert(t)
+	reviews.AssertExpectations(t)
+
+	// Nothing is left to decide until the user uploads again.
+	doc.ReviewStatus, doc.RejectionReason = domain.KYCDocumentInfoRequested, &notes
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(doc), nil).Once()
+	_, err = s.DecideProfile(ctx, user.ID, reviewer, domain.KYCDecisionApprove, "")
+	assert.ErrorIs(t, err, ErrNothingToReview)
+
+	full := passport(user.ID, domain.KYCDocumentInReview)
+	full.ReplacesID, doc.SupersededAt = &doc.ID, &full.CreatedAt
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(full, doc), nil).Times(3)
+	m.users.On("UpdateKYCStatus", ctx, user.ID, domain.KYCStatusVerified).Return(nil).Once()
+	decides(m, string(domain.KYCStatusVerified), "", full)
+	var approved *domain.KYCReview
+	reviews.On("CreateReview", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		approved = args.Get(1).(*domain.KYCReview)
+	}).Return(nil).Once()
+	_, err = s.DecideProfile(ctx, user.ID, reviewer, domain.KYCDecisionApprove, "")
+	require.NoError(t, err)
+	assert.Nil(t, approved.Notes)
+	m.assert(t)
+	reviews.AssertExpectations(t)
+
+	user.KYCStatus = domain.KYCStatusVerified
+	m.users.On("FindByID", ctx, user.ID).Return(user, nil).Once()
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(full, doc), nil).Once()
+	reviews.On("ListReviews", ctx, user.ID).Return([]domain.KYCReview{*approved, *requested}, nil).Once()
+	profile, err := s.GetProfile(ctx, user.ID, reviewer)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusVerified, profile.KYCStatus)
+	require.Len(t, profile.Reviews, 2)
+	assert.Equal(t, domain.KYCDecisionApprove, profile.Reviews[0].Decision)
+	assert.Equal(t, domain.KYCDecisionRequestInfo, profile.Reviews[1].Decision)
+	require.Len(t, profile.Documents, 1)
+	require.Len(t, profile.Documents[0].Attempts, 1)
+	assert.Equal(t, domain.KYCDocumentInfoRequested, profile.Documents[0].Attempts[0].Status)
+	assert.Empty(t, profile.Documents[0].Files, "links are not configured")
+}
+
+func TestDocumentLinks(t *testing.T) {
+	s, m, _, files := newScanningService(t)
+	reviews := &MockReviews{}
+	s.WithReviewQueue(reviews).WithDocumentLinks(files, "secret", time.Minute)
+	user := &domain.User{ID: uuid.New()}
+	doc := passport(user.ID, domain.KYCDocumentInReview)
+	front := upload(t, files, "p.png", "passport")
+	doc.FrontImageURL, doc.VirusScanStatus = &front, domain.VirusScanClean
+	m.users.On("FindByID", ctx, user.ID).Return(user, nil)
+	reviews.On("ListReviews", ctx, user.ID).Return(nil, nil)
+
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(doc), nil).Once()
+	profile, err := s.GetProfile(ctx, user.ID, reviewer)
+	require.NoError(t, err)
+	require.Len(t, profile.Documents, 1)
+	links := profile.Documents[0].Files
+	require.Len(t, links, 1, "only files that were uploaded are linked")
+	assert.Equal(t, "front", links[0].Side)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), links[0].ExpiresAt, 2*time.Second)
+
+	link, err := url.Parse(links[0].URL)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/kyc/documents/"+doc.ID.String()+"/files/front", link.Path)
+	q := link.Query()
+
+	m.repo.On("GetByID", ctx, doc.ID).Return(doc, nil).Once()
+	f, name, err := s.OpenDocumentFile(ctx, doc.ID, "front", q.Get("expires"), q.Get("signature"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(f)
+	f.Close()
+	assert.Equal(t, "passport", string(body))
+	assert.True(t, strings.HasSuffix(name, ".png"))
+
+	_, _, err = s.OpenDocumentFile(ctx, doc.ID, "selfie", q.Get("expires"), q.Get("signature"))
+	assert.ErrorIs(t, err, ErrInvalidLink, "a signature covers one file")
+	_, _, err = s.OpenDocumentFile(ctx, doc.ID, "front", "1", q.Get("signature"))
+	assert.ErrorIs(t, err, ErrInvalidLink, "expired")
+	m.assert(t)
+
+	// Quarantining a document stops links already handed out.
+	doc.VirusScanStatus, doc.ReviewStatus = domain.VirusScanInfected, domain.KYCDocumentQuarantined
+	m.repo.On("GetByID", ctx, doc.ID).Return(doc, nil).Once()
+	_, _, err = s.OpenDocumentFile(ctx, doc.ID, "front", q.Get("expires"), q.Get("signature"))
+	assert.ErrorIs(t, err, ErrDocumentNotScanned)
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(doc), nil).Once()
+	profile, err = s.GetProfile(ctx, user.ID, reviewer)
+	require.NoError(t, err)
+	assert.Empty(t, profile.Documents[0].Files)
+	m.repo.AssertExpectations(t)
+}
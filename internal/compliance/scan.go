@@ -0,0 +1,179 @@
+package compliance
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/virusscan"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned while documents are scanned or verified.
+var (
+	ErrDocumentNotScanned = errors.New("kyc document has not passed the virus scan")
+	ErrScanNotNeeded      = errors.New("kyc document has already been scanned")
+)
+
+// DocumentFiles reads and quarantines the files behind KYC document URLs.
+type DocumentFiles interface {
+	Open(ctx context.Context, url string) (io.ReadCloser, error)
+	// Quarantine moves a file where it can no longer be served.
+	Quarantine(ctx context.Context, url string) error
+}
+
+// UploadDir serves document files saved under Dir, as /uploads/kyc/<name>
+// URLs, and quarantines them by moving them to QuarantineDir.
+type UploadDir struct {
+	Dir           string
+	QuarantineDir string
+}
+
+// file maps a document URL to its file. Only the base name is used, so a
+// URL cannot reach outside Dir.
+func (u UploadDir) file(url string) string {
+	return filepath.Join(u.Dir, path.Base(url))
+}
+
+func (u UploadDir) Open(_ context.Context, url string) (io.ReadCloser, error) {
+	return os.Open(u.file(url))
+}
+
+func (u UploadDir) Quarantine(_ context.Context, url string) error {
+	if err := os.MkdirAll(u.QuarantineDir, 0700); err != nil {
+		return err
+	}
+	return os.Rename(u.file(url), filepath.Join(u.QuarantineDir, path.Base(url)))
+}
+
+// WithScanner scans every uploaded document with scanner before it is
+// reviewed, reading its files from files.
+func (s *Service) WithScanner(scanner virusscan.Scanner, files DocumentFiles) *Service {
+	s.scanner = scanner
+	s.files = files
+	return s
+}
+
+// initialScanStatus is the scan status a new upload starts with.
+func (s *Service) initialScanStatus() domain.VirusScanStatus {
+	if s.scanner == nil {
+		return domain.VirusScanSkipped
+	}
+	return domain.VirusScanPending
+}
+
+// screen scans a new upload and, if it is clean, queues it for review.
+// Without a scanner documents go straight to review.
+func (s *Service) screen(ctx context.Context, doc *domain.KYCDocument) {
+	if s.scanner == nil {
+		s.startReview(ctx, doc)
+		return
+	}
+	if err := s.repo.SetReviewStatus(ctx, doc.ID, domain.KYCDocumentScanning, nil); err == nil {
+		doc.ReviewStatus = domain.KYCDocumentScanning
+	}
+	res, err := s.scanFiles(ctx, doc)
+	s.applyScan(ctx, doc, res, err)
+}
+
+// documentURLs are the files uploaded for doc.
+func documentURLs(doc *domain.KYCDocument) []string {
+	var urls []string
+	for _, u := range []*string{doc.FrontImageURL, doc.BackImageURL, doc.SelfieImageURL} {
+		if u != nil && *u != "" {
+			urls = append(urls, *u)
+		}
+	}
+	return urls
+}
+
+// scanFiles scans each of doc's files, stopping at the first infected one.
+func (s *Service) scanFiles(ctx context.Context, doc *domain.KYCDocument) (virusscan.Result, error) {
+	for _, url := range documentURLs(doc) {
+		f, err := s.files.Open(ctx, url)
+		if err != nil {
+			return virusscan.Result{}, errors.Wrap(err, "failed to open kyc document")
+		}
+		res, err := s.scanner.Scan(ctx, f)
+		f.Close()
+		if err != nil || res.Infected {
+			return res, err
+		}
+	}
+	return virusscan.Result{}, nil
+}
+
+// applyScan records a scan. Clean documents go on to review; infected ones
+// are quarantined: their files are moved out of reach and the document
+// can be replaced but never verified. Documents that could not be scanned
+// wait for RescanDocument.
+func (s *Service) applyScan(ctx context.Context, doc *domain.KYCDocument, res virusscan.Result, scanErr error) {
+	now := time.Now()
+	doc.ScannedAt = &now
+	switch {
+	case scanErr != nil:
+		doc.VirusScanStatus = domain.VirusScanFailed
+		_ = s.repo.SetVirusScan(ctx, doc.ID, domain.VirusScanFailed, nil)
+		s.auditScan(ctx, doc, "kyc_scan_failed", "failure", domain.Metadata{"error": scanErr.Error()})
+	case res.Infected:
+		doc.VirusScanStatus, doc.VirusSignature = domain.VirusScanInfected, &res.Signature
+		_ = s.repo.SetVirusScan(ctx, doc.ID, domain.VirusScanInfected, &res.Signature)
+		meta := domain.Metadata{"signature": res.Signature}
+		for _, url := range documentURLs(doc) {
+			if err := s.files.Quarantine(ctx, url); err != nil && !os.IsNotExist(err) {
+				meta["quarantine_error"] = err.Error()
+			}
+		}
+		if err := s.repo.SetReviewStatus(ctx, doc.ID, domain.KYCDocumentQuarantined, nil); err == nil {
+			doc.ReviewStatus = domain.KYCDocumentQuarantined
+		}
+		s.auditScan(ctx, doc, "kyc_quarantine", "failure", meta)
+	default:
+		doc.VirusScanStatus = domain.VirusScanClean
+		_ = s.repo.SetVirusScan(ctx, doc.ID, domain.VirusScanClean, nil)
+		s.startReview(ctx, doc)
+	}
+}
+
+func (s *Service) auditScan(ctx context.Context, doc *domain.KYCDocument, action, status string, meta domain.Metadata) {
+	if s.auditRepo == nil {
+		return
+	}
+	_ = s.auditRepo.Create(ctx, &domain.AuditLog{
+		ID:         uuid.New(),
+		Action:     action,
+		Resource:   "kyc_documents",
+		ResourceID: doc.ID.String(),
+		UserID:     &doc.UserID,
+		Status:     status,
+		CreatedAt:  time.Now(),
+		Metadata:   meta,
+	})
+}
+
+// RescanDocument scans a document again whose earlier scan never reached a
+// verdict, e.g. because clamd was down.
+func (s *Service) RescanDocument(ctx context.Context, id uuid.UUID) (*domain.KYCDocument, error) {
+	if s.scanner == nil {
+		return nil, errors.New("virus scanning is not configured")
+	}
+	doc, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrDocumentNotFound
+	}
+	if doc.SupersededAt != nil {
+		return nil, ErrDocumentReplaced
+	}
+	if doc.VirusScanStatus != domain.VirusScanPending && doc.VirusScanStatus != domain.VirusScanFailed {
+		return nil, ErrScanNotNeeded
+	}
+	res, err := s.scanFiles(ctx, doc)
+	s.applyScan(ctx, doc, res, err)
+	return doc, nil
+}
@@ -0,0 +1,220 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/aml"
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by sanctions screening.
+var (
+	ErrSanctionsMatch = errors.New("user matches a sanctions list")
+	ErrNotScreened    = errors.New("user could not be screened against sanctions lists")
+	ErrNothingToClear = errors.New("user has no open sanctions match")
+	errNoScreening    = errors.New("sanctions screening is not configured")
+)
+
+// ScreeningRepository stores AML screenings.
+type ScreeningRepository interface {
+	// CreateScreening also copies the screening's risk score to the user.
+	CreateScreening(ctx context.Context, s *domain.AMLScreening) error
+	LatestScreening(ctx context.Context, userID uuid.UUID) (*domain.AMLScreening, error)
+	ClearScreening(ctx context.Context, id, clearedBy uuid.UUID, reason string) (bool, error)
+}
+
+// WithScreening screens users against sanctions and PEP lists when they
+// submit KYC documents, and refuses to verify anyone with an open
+// sanctions match.
+func (s *Service) WithScreening(provider aml.Provider, repo ScreeningRepository) *Service {
+	s.aml = provider
+	s.screenings = repo
+	return s
+}
+
+// screenedNames are the names a user is screened under: their own and,
+// for businesses, the business's.
+func screenedNames(u *domain.User) []string {
+	var names []string
+	if n := strings.TrimSpace(u.FirstName + " " + u.LastName); n != "" {
+		names = append(names, n)
+	}
+	if u.BusinessName != nil && strings.TrimSpace(*u.BusinessName) != "" {
+		names = append(names, strings.TrimSpace(*u.BusinessName))
+	}
+	return names
+}
+
+// screenedCountries are the user's country and those that issued their
+// current documents.
+func (s *Service) screenedCountries(ctx context.Context, u *domain.User) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(c string) {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" && !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	add(u.CountryCode)
+	if docs, err := s.repo.GetByUserID(ctx, u.ID); err == nil {
+		for _, d := range docs {
+			if d.SupersededAt == nil && d.IssuingCountry != nil {
+				add(*d.IssuingCountry)
+			}
+		}
+	}
+	return out
+}
+
+// ScreenUser screens a user now and records the result as their AML
+// profile.
+func (s *Service) ScreenUser(ctx context.Context, userID uuid.UUID) (*domain.AMLScreening, error) {
+	if s.aml == nil {
+		return nil, errNoScreening
+	}
+	user, err := s.userProvider.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+	names := screenedNames(user)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no name on file", ErrNotScreened)
+	}
+	countries := s.screenedCountries(ctx, user)
+
+	sc := &domain.AMLScreening{
+		ID:           uuid.New(),
+		UserID:       userID,
+		Provider:     s.aml.Name(),
+		ScreenedName: strings.Join(names, " / "),
+		Countries:    countries,
+		Matches:      domain.AMLMatches{},
+		ScreenedAt:   time.Now(),
+	}
+	for _, name := range names {
+		res, err := s.aml.Screen(ctx, aml.Subject{Name: name, Countries: countries})
+		if err != nil {
+			s.auditScreening(ctx, userID, "failure", domain.Metadata{"error": err.Error()})
+			return nil, fmt.Errorf("%w: %v", ErrNotScreened, err)
+		}
+		sc.SanctionHit = sc.SanctionHit || res.SanctionHit
+		sc.PEP = sc.PEP || res.PEP
+		sc.CountryHit = sc.CountryHit || res.CountryHit
+		sc.RiskScore = max(sc.RiskScore, res.RiskScore)
+		for _, m := range res.Matches {
+			sc.Matches = append(sc.Matches, domain.AMLMatch{
+				List:      m.List,
+				Kind:      string(m.Kind),
+				EntryID:   m.EntryID,
+				Name:      m.Name,
+				Score:     m.Score,
+				Programs:  m.Programs,
+				Countries: m.Countries,
+			})
+		}
+	}
+	if err := s.screenings.CreateScreening(ctx, sc); err != nil {
+		return nil, err
+	}
+	s.auditScreening(ctx, userID, "success", domain.Metadata{
+		"screening_id": sc.ID.String(),
+		"sanction_hit": sc.SanctionHit,
+		"pep":          sc.PEP,
+		"country_hit":  sc.CountryHit,
+		"risk_score":   sc.RiskScore,
+	})
+	return sc, nil
+}
+
+func (s *Service) auditScreening(ctx context.Context, userID uuid.UUID, status string, meta domain.Metadata) {
+	if s.auditRepo == nil {
+		return
+	}
+	_ = s.auditRepo.Create(ctx, &domain.AuditLog{
+		ID:         uuid.New(),
+		Action:     "aml_screening",
+		Resource:   "users",
+		ResourceID: userID.String(),
+		Status:     status,
+		CreatedAt:  time.Now(),
+		Metadata:   meta,
+	})
+}
+
+// LatestScreening returns the user's AML profile, or nil if they were
+// never screened.
+func (s *Service) LatestScreening(ctx context.Context, userID uuid.UUID) (*domain.AMLScreening, error) {
+	if s.screenings == nil {
+		return nil, errNoScreening
+	}
+	return s.screenings.LatestScreening(ctx, userID)
+}
+
+// ClearScreening marks the user's open sanctions match a false positive,
+// so they can be verified.
+func (s *Service) ClearScreening(ctx context.Context, userID uuid.UUID, reason string, clearedBy uuid.UUID) (*domain.AMLScreening, error) {
+	if s.screenings == nil {
+		return nil, errNoScreening
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("a reason is required")
+	}
+	sc, err := s.screenings.LatestScreening(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sc == nil || !sc.BlocksVerification() {
+		return nil, ErrNothingToClear
+	}
+	cleared, err := s.screenings.ClearScreening(ctx, sc.ID, clearedBy, reason)
+	if err != nil {
+		return nil, err
+	}
+	if !cleared {
+		return nil, ErrNothingToClear
+	}
+	now := time.Now()
+	sc.ClearedBy, sc.ClearedAt, sc.ClearReason = &clearedBy, &now, &reason
+	if s.auditRepo != nil {
+		_ = s.auditRepo.Create(ctx, &domain.AuditLog{
+			ID:         uuid.New(),
+			Action:     "aml_screening_cleared",
+			Resource:   "users",
+			ResourceID: userID.String(),
+			UserID:     &clearedBy,
+			Status:     "success",
+			CreatedAt:  now,
+			Metadata:   domain.Metadata{"screening_id": sc.ID.String(), "reason": reason},
+		})
+	}
+	return sc, nil
+}
+
+// requireScreened fails unless the user has been screened without an open
+// sanctions match, screening them first if they never were.
+func (s *Service) requireScreened(ctx context.Context, userID uuid.UUID) error {
+	if s.aml == nil {
+		return nil
+	}
+	sc, err := s.screenings.LatestScreening(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		if sc, err = s.ScreenUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+	if sc.BlocksVerification() {
+		return ErrSanctionsMatch
+	}
+	return nil
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"time"
 
+	"kyd/internal/aml"
 	"kyd/internal/domain"
+	"kyd/internal/virusscan"
 	"kyd/pkg/errors"
 
 	"github.com/google/uuid"
 )
 
 type UserProvider interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
 	UpdateKYCStatus(ctx context.Context, userID uuid.UUID, status domain.KYCStatus) error
 	FindAllByKYCStatus(ctx context.Context, status string, limit, offset int) ([]*domain.User, error)
 	CountAllByKYCStatus(ctx context.Context, status string) (int, error)
@@ -23,16 +26,40 @@ type Repository interface {
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.KYCDocument, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.KYCDocument, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, status string, notes *string, verifiedBy *uuid.UUID) error
+	SetReviewStatus(ctx context.Context, id uuid.UUID, status domain.KYCDocumentStatus, reason *string) error
+	Resubmit(ctx context.Context, doc *domain.KYCDocument) error
+	SetVirusScan(ctx context.Context, id uuid.UUID, status domain.VirusScanStatus, signature *string) error
 }
 
+// Errors returned by ResubmitDocument.
+var (
+	ErrDocumentNotFound    = errors.New("kyc document not found")
+	ErrDocumentReplaced    = errors.New("kyc document has already been replaced")
+	ErrDocumentNotReplaced = errors.New("accepted kyc documents cannot be replaced")
+)
+
 type AuditRepository interface {
 	Create(ctx context.Context, log *domain.AuditLog) error
 }
 
+// Notifier tells users about decisions on their KYC application.
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
 type Service struct {
 	repo         Repository
 	userProvider UserProvider
 	auditRepo    AuditRepository
+	scanner      virusscan.Scanner
+	files        DocumentFiles
+	aml          aml.Provider
+	screenings   ScreeningRepository
+	reviews      ReviewRepository
+	linkFiles    DocumentFiles
+	linkSecret   []byte
+	linkTTL      time.Duration
+	notifier     Notifier
 }
 
 func NewService(repo Repository, userProvider UserProvider, auditRepo AuditRepository) *Service {
@@ -43,6 +70,13 @@ func NewService(repo Repository, userProvider UserProvider, auditRepo AuditRepos
 	}
 }
 
+// WithNotifier tells users when their KYC application is approved or
+// rejected.
+func (s *Service) WithNotifier(n Notifier) *Service {
+	s.notifier = n
+	return s
+}
+
 type SubmitKYCRequest struct {
 	UserID         uuid.UUID
 	DocumentType   string
@@ -64,6 +98,8 @@ func (s *Service) SubmitKYC(ctx context.Context, req *SubmitKYCRequest) (*domain
 		BackImageURL:       &req.BackImageURL,
 		SelfieImageURL:     &req.SelfieImageURL,
 		VerificationStatus: string(domain.KYCStatusPending),
+		ReviewStatus:       domain.KYCDocumentUploaded,
+		VirusScanStatus:    s.initialScanStatus(),
 		CreatedAt:          time.Now(),
 		UpdatedAt:          time.Now(),
 	}
@@ -71,6 +107,7 @@ func (s *Service) SubmitKYC(ctx context.Context, req *SubmitKYCRequest) (*domain
 	if err := s.repo.Create(ctx, doc); err != nil {
 		return nil, err
 	}
+	s.screen(ctx, doc)
 
 	// Log audit trail
 	if s.auditRepo != nil {
@@ -94,11 +131,185 @@ func (s *Service) SubmitKYC(ctx context.Context, req *SubmitKYCRequest) (*domain
 		return nil, errors.Wrap(err, "failed to update user kyc status")
 	}
 
+	// A failed screening is audited and retried before verification
+	if s.aml != nil {
+		_, _ = s.ScreenUser(ctx, req.UserID)
+	}
+
+	return doc, nil
+}
+
+// startReview queues an uploaded document for review.
+func (s *Service) startReview(ctx context.Context, doc *domain.KYCDocument) {
+	if err := s.repo.SetReviewStatus(ctx, doc.ID, domain.KYCDocumentInReview, nil); err == nil {
+		doc.ReviewStatus = domain.KYCDocumentInReview
+	}
+}
+
+// ResubmitDocumentRequest replaces an earlier attempt at a document with a
+// new upload.
+type ResubmitDocumentRequest struct {
+	UserID         uuid.UUID
+	DocumentID     uuid.UUID
+	FrontImageURL  string
+	BackImageURL   string
+	SelfieImageURL string
+}
+
+// ResubmitDocument stores a new attempt at one of the user's documents. The
+// replaced attempt keeps its status and rejection reason as history; the
+// user's application goes back to pending.
+func (s *Service) ResubmitDocument(ctx context.Context, req *ResubmitDocumentRequest) (*domain.KYCDocument, error) {
+	old, err := s.repo.GetByID(ctx, req.DocumentID)
+	if err != nil || old.UserID != req.UserID {
+		return nil, ErrDocumentNotFound
+	}
+	if old.SupersededAt != nil {
+		return nil, ErrDocumentReplaced
+	}
+	if old.ReviewStatus == domain.KYCDocumentAccepted {
+		return nil, ErrDocumentNotReplaced
+	}
+
+	now := time.Now()
+	doc := &domain.KYCDocument{
+		ID:                 uuid.New(),
+		UserID:             req.UserID,
+		DocumentType:       old.DocumentType,
+		DocumentNumber:     old.DocumentNumber,
+		IssuingCountry:     old.IssuingCountry,
+		IssueDate:          old.IssueDate,
+		ExpiryDate:         old.ExpiryDate,
+		FrontImageURL:      &req.FrontImageURL,
+		BackImageURL:       &req.BackImageURL,
+		SelfieImageURL:     &req.SelfieImageURL,
+		VerificationStatus: string(domain.KYCStatusPending),
+		ReviewStatus:       domain.KYCDocumentUploaded,
+		ReplacesID:         &old.ID,
+		VirusScanStatus:    s.initialScanStatus(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.repo.Resubmit(ctx, doc); err != nil {
+		return nil, err
+	}
+	s.screen(ctx, doc)
+
+	if s.auditRepo != nil {
+		_ = s.auditRepo.Create(ctx, &domain.AuditLog{
+			ID:         uuid.New(),
+			Action:     "kyc_resubmission",
+			Resource:   "kyc_documents",
+			ResourceID: doc.ID.String(),
+			UserID:     &req.UserID,
+			Status:     "success",
+			CreatedAt:  now,
+			Metadata: domain.Metadata{
+				"document_type": doc.DocumentType,
+				"replaces":      old.ID.String(),
+			},
+		})
+	}
+
+	if err := s.userProvider.UpdateKYCStatus(ctx, req.UserID, domain.KYCStatusPending); err != nil {
+		return nil, errors.Wrap(err, "failed to update user kyc status")
+	}
 	return doc, nil
 }
 
-func (s *Service) GetKYCStatus(ctx context.Context, userID uuid.UUID) ([]domain.KYCDocument, error) {
-	return s.repo.GetByUserID(ctx, userID)
+// DocumentAttempt is an earlier upload of a document that was replaced.
+type DocumentAttempt struct {
+	ID              uuid.UUID                `json:"id"`
+	Status          domain.KYCDocumentStatus `json:"status"`
+	RejectionReason *string                  `json:"rejection_reason,omitempty"`
+	SubmittedAt     time.Time                `json:"submitted_at"`
+	ReplacedAt      *time.Time               `json:"replaced_at,omitempty"`
+}
+
+// DocumentProgress is where one document is in review, with the attempts it
+// replaced, newest first.
+type DocumentProgress struct {
+	ID              uuid.UUID                `json:"id"`
+	DocumentType    string                   `json:"document_type"`
+	IssuingCountry  *string                  `json:"issuing_country,omitempty"`
+	Status          domain.KYCDocumentStatus `json:"status"`
+	RejectionReason *string                  `json:"rejection_reason,omitempty"`
+	SubmittedAt     time.Time                `json:"submitted_at"`
+	UpdatedAt       time.Time                `json:"updated_at"`
+	ReviewedAt      *time.Time               `json:"reviewed_at,omitempty"`
+	Attempts        []DocumentAttempt        `json:"attempts"`
+}
+
+// KYCStatus is the user's application status with per-document progress.
+type KYCStatus struct {
+	Status    domain.KYCStatus   `json:"status"`
+	Documents []DocumentProgress `json:"documents"`
+}
+
+func (s *Service) GetKYCStatus(ctx context.Context, userID uuid.UUID) (*KYCStatus, error) {
+	user, err := s.userProvider.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &KYCStatus{Status: user.KYCStatus, Documents: documentProgress(docs)}, nil
+}
+
+// documentProgress groups documents into current attempts, each with the
+// chain of attempts it replaced. docs are newest first.
+func documentProgress(docs []domain.KYCDocument) []DocumentProgress {
+	byID := make(map[uuid.UUID]*domain.KYCDocument, len(docs))
+	for i := range docs {
+		byID[docs[i].ID] = &docs[i]
+	}
+	out := []DocumentProgress{}
+	for i := range docs {
+		d := &docs[i]
+		if d.SupersededAt != nil {
+			continue
+		}
+		p := DocumentProgress{
+			ID:              d.ID,
+			DocumentType:    d.DocumentType,
+			IssuingCountry:  d.IssuingCountry,
+			Status:          d.ReviewStatus,
+			RejectionReason: d.RejectionReason,
+			SubmittedAt:     d.CreatedAt,
+			UpdatedAt:       d.UpdatedAt,
+			ReviewedAt:      d.VerifiedAt,
+			Attempts:        []DocumentAttempt{},
+		}
+		seen := map[uuid.UUID]bool{d.ID: true}
+		for prev := d.ReplacesID; prev != nil && !seen[*prev]; {
+			old, ok := byID[*prev]
+			if !ok {
+				break
+			}
+			seen[old.ID] = true
+			p.Attempts = append(p.Attempts, DocumentAttempt{
+				ID:              old.ID,
+				Status:          old.ReviewStatus,
+				RejectionReason: old.RejectionReason,
+				SubmittedAt:     old.CreatedAt,
+				ReplacedAt:      old.SupersededAt,
+			})
+			prev = old.ReplacesID
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// documentDecision is the review status a document takes from an
+// application decision.
+func documentDecision(status string) domain.KYCDocumentStatus {
+	if status == string(domain.KYCStatusVerified) {
+		return domain.KYCDocumentAccepted
+	}
+	return domain.KYCDocumentRejected
 }
 
 type KYCApplication struct {
@@ -111,6 +322,8 @@ type KYCApplication struct {
 	Documents   interface{} `json:"documents,omitempty"`
 	Name        string      `json:"name"`
 	Email       string      `json:"email"`
+	// Screening is the latest sanctions and PEP screening, if any.
+	Screening *domain.AMLScreening `json:"screening,omitempty"`
 }
 
 func (s *Service) ListApplications(ctx context.Context, status string, limit, offset int) ([]KYCApplication, int, error) {
@@ -153,11 +366,19 @@ func (s *Service) ListApplications(ctx context.Context, status string, limit, of
 			Email:       u.Email,
 			Documents:   docs,
 		}
+		if s.screenings != nil {
+			apps[i].Screening, _ = s.screenings.LatestScreening(ctx, u.ID)
+		}
 	}
 
 	return apps, total, nil
 }
 
+// CountApplications returns how many users have the given KYC status.
+func (s *Service) CountApplications(ctx context.Context, status string) (int, error) {
+	return s.userProvider.CountAllByKYCStatus(ctx, status)
+}
+
 func (s *Service) ReviewApplication(ctx context.Context, userID uuid.UUID, status string, reason string, reviewerID uuid.UUID) error {
 	// Validate status
 	switch status {
@@ -167,6 +388,17 @@ func (s *Service) ReviewApplication(ctx context.Context, userID uuid.UUID, statu
 		return errors.New("invalid kyc status")
 	}
 
+	// Nobody is verified on documents that have not passed the virus scan,
+	// or while they match a sanctions list
+	if status == string(domain.KYCStatusVerified) {
+		if err := s.requireScanned(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.requireScreened(ctx, userID); err != nil {
+			return err
+		}
+	}
+
 	// Update User Status
 	if err := s.userProvider.UpdateKYCStatus(ctx, userID, domain.KYCStatus(status)); err != nil {
 		return err
@@ -176,9 +408,13 @@ func (s *Service) ReviewApplication(ctx context.Context, userID uuid.UUID, statu
 	docs, err := s.repo.GetByUserID(ctx, userID)
 	if err == nil {
 		for _, doc := range docs {
-			// Update document status if it's currently pending or if we are forcing an update
-			// We update all documents to match the application decision
+			// Replaced attempts keep the outcome they had as history
+			if doc.SupersededAt != nil {
+				continue
+			}
+			// We update all current documents to match the application decision
 			_ = s.repo.UpdateStatus(ctx, doc.ID, status, &reason, &reviewerID)
+			_ = s.repo.SetReviewStatus(ctx, doc.ID, documentDecision(status), &reason)
 		}
 	} else {
 		// Log error but don't fail the operation since user status is updated
@@ -202,6 +438,17 @@ func (s *Service) ReviewApplication(ctx context.Context, userID uuid.UUID, statu
 		})
 	}
 
+	if s.notifier != nil {
+		data := map[string]interface{}{"status": status}
+		if status == string(domain.KYCStatusRejected) {
+			data["reason"] = reason
+		}
+		// The decision stands whether or not the user hears of it
+		go func() {
+			_ = s.notifier.Notify(context.Background(), userID, "KYC_STATUS_CHANGED", data)
+		}()
+	}
+
 	return nil
 }
 
@@ -214,5 +461,33 @@ func (s *Service) ReviewKYC(ctx context.Context, docID uuid.UUID, status string,
 		return errors.New("invalid kyc status")
 	}
 
-	return s.repo.UpdateStatus(ctx, docID, status, &notes, &reviewerID)
+	if status == string(domain.KYCStatusVerified) {
+		doc, err := s.repo.GetByID(ctx, docID)
+		if err != nil {
+			return ErrDocumentNotFound
+		}
+		if !doc.ScanCleared() {
+			return ErrDocumentNotScanned
+		}
+	}
+
+	if err := s.repo.UpdateStatus(ctx, docID, status, &notes, &reviewerID); err != nil {
+		return err
+	}
+	return s.repo.SetReviewStatus(ctx, docID, documentDecision(status), &notes)
+}
+
+// requireScanned fails unless every current document of the user has
+// passed the virus scan.
+func (s *Service) requireScanned(ctx context.Context, userID uuid.UUID) error {
+	docs, err := s.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range docs {
+		if docs[i].SupersededAt == nil && !docs[i].ScanCleared() {
+			return ErrDocumentNotScanned
+		}
+	}
+	return nil
 }
@@ -0,0 +1,514 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/aml"
+	"kyd/internal/domain"
+	"kyd/internal/virusscan"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, doc *domain.KYCDocument) error {
+	return m.Called(ctx, doc).Error(0)
+}
+
+func (m *MockRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.KYCDocument, error) {
+	args := m.Called(ctx, userID)
+	docs, _ := args.Get(0).([]domain.KYCDocument)
+	return append([]domain.KYCDocument(nil), docs...), args.Error(1)
+}
+
+func (m *MockRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.KYCDocument, error) {
+	args := m.Called(ctx, id)
+	doc, _ := args.Get(0).(*domain.KYCDocument)
+	if doc != nil {
+		cp := *doc
+		doc = &cp
+	}
+	return doc, args.Error(1)
+}
+
+func (m *MockRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string, notes *string, verifiedBy *uuid.UUID) error {
+	return m.Called(ctx, id, status, notes, verifiedBy).Error(0)
+}
+
+func (m *MockRepository) SetReviewStatus(ctx context.Context, id uuid.UUID, status domain.KYCDocumentStatus, reason *string) error {
+	return m.Called(ctx, id, status, reason).Error(0)
+}
+
+func (m *MockRepository) Resubmit(ctx context.Context, doc *domain.KYCDocument) error {
+	return m.Called(ctx, doc).Error(0)
+}
+
+func (m *MockRepository) SetVirusScan(ctx context.Context, id uuid.UUID, status domain.VirusScanStatus, signature *string) error {
+	return m.Called(ctx, id, status, signature).Error(0)
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+func (m *MockUsers) UpdateKYCStatus(ctx context.Context, userID uuid.UUID, status domain.KYCStatus) error {
+	return m.Called(ctx, userID, status).Error(0)
+}
+
+func (m *MockUsers) FindAllByKYCStatus(ctx context.Context, status string, limit, offset int) ([]*domain.User, error) {
+	args := m.Called(ctx, status, limit, offset)
+	users, _ := args.Get(0).([]*domain.User)
+	return users, args.Error(1)
+}
+
+func (m *MockUsers) CountAllByKYCStatus(ctx context.Context, status string) (int, error) {
+	args := m.Called(ctx, status)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUsers) FindAll(ctx context.Context, limit, offset int, userType string) ([]*domain.User, error) {
+	args := m.Called(ctx, limit, offset, userType)
+	users, _ := args.Get(0).([]*domain.User)
+	return users, args.Error(1)
+}
+
+func (m *MockUsers) CountAll(ctx context.Context, userType string) (int, error) {
+	args := m.Called(ctx, userType)
+	return args.Int(0), args.Error(1)
+}
+
+type MockScreenings struct {
+	mock.Mock
+}
+
+func (m *MockScreenings) CreateScreening(ctx context.Context, s *domain.AMLScreening) error {
+	return m.Called(ctx, s).Error(0)
+}
+
+func (m *MockScreenings) LatestScreening(ctx context.Context, userID uuid.UUID) (*domain.AMLScreening, error) {
+	args := m.Called(ctx, userID)
+	sc, _ := args.Get(0).(*domain.AMLScreening)
+	if sc != nil {
+		cp := *sc
+		sc = &cp
+	}
+	return sc, args.Error(1)
+}
+
+func (m *MockScreenings) ClearScreening(ctx context.Context, id, clearedBy uuid.UUID, reason string) (bool, error) {
+	args := m.Called(ctx, id, clearedBy, reason)
+	return args.Bool(0), args.Error(1)
+}
+
+type mocks struct {
+	repo  *MockRepository
+	users *MockUsers
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.users.AssertExpectations(t)
+}
+
+var (
+	ctx      = context.Background()
+	reviewer = uuid.New()
+)
+
+func newService() (*Service, *mocks) {
+	m := &mocks{repo: &MockRepository{}, users: &MockUsers{}}
+	return NewService(m.repo, m.users, nil), m
+}
+
+// passport is a stored MW passport of user's at status, submitted an hour
+// ago and not scanned.
+func passport(user uuid.UUID, status domain.KYCDocumentStatus) *domain.KYCDocument {
+	number, country, front := "P1", "MW", "/uploads/kyc/p.png"
+	at := time.Now().Add(-time.Hour)
+	return &domain.KYCDocument{
+		ID:                 uuid.New(),
+		UserID:             user,
+		DocumentType:       "passport",
+		DocumentNumber:     &number,
+		IssuingCountry:     &country,
+		FrontImageURL:      &front,
+		VerificationStatus: string(domain.KYCStatusPending),
+		ReviewStatus:       status,
+		VirusScanStatus:    domain.VirusScanSkipped,
+		CreatedAt:          at,
+		UpdatedAt:          at,
+	}
+}
+
+// docs lists documents as the repository returns them.
+func docs(d ...*domain.KYCDocument) []domain.KYCDocument {
+	out := make([]domain.KYCDocument, len(d))
+	for i := range d {
+		out[i] = *d[i]
+	}
+	return out
+}
+
+// decides expects the current documents to take the decision status.
+func decides(m *mocks, status string, reason string, d ...*domain.KYCDocument) {
+	for _, doc := range d {
+		m.repo.On("UpdateStatus", ctx, doc.ID, status, &reason, &reviewer).Return(nil).Once()
+		m.repo.On("SetReviewStatus", ctx, doc.ID, documentDecision(status), &reason).Return(nil).Once()
+	}
+}
+
+func submitRequest(user uuid.UUID, front string) *SubmitKYCRequest {
+	return &SubmitKYCRequest{UserID: user, DocumentType: "passport", DocumentNumber: "P1", IssuingCountry: "MW", FrontImageURL: front}
+}
+
+func TestSubmitKYC_GoesToReview(t *testing.T) {
+	s, m := newService()
+	user := uuid.New()
+	m.repo.On("Create", ctx, mock.MatchedBy(func(d *domain.KYCDocument) bool {
+		return d.UserID == user && *d.DocumentNumber == "P1" && d.ReviewStatus == domain.KYCDocumentUploaded && d.VirusScanStatus == domain.VirusScanSkipped
+	})).Return(nil).Once()
+	m.repo.On("SetReviewStatus", ctx, mock.Anything, domain.KYCDocumentInReview, (*string)(nil)).Return(nil).Once()
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusPending).Return(nil).Once()
+
+	doc, err := s.SubmitKYC(ctx, submitRequest(user, ""))
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCDocumentInReview, doc.ReviewStatus)
+	m.assert(t)
+
+	m.users.On("FindByID", ctx, user).Return(&domain.User{ID: user, KYCStatus: domain.KYCStatusPending}, nil).Once()
+	m.repo.On("GetByUserID", ctx, user).Return(docs(doc), nil).Once()
+	status, err := s.GetKYCStatus(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusPending, status.Status)
+	require.Len(t, status.Documents, 1)
+	assert.Equal(t, domain.KYCDocumentInReview, status.Documents[0].Status)
+	assert.Empty(t, status.Documents[0].Attempts)
+
+	t.Run("a failed save changes nothing", func(t *testing.T) {
+		s, m := newService()
+		m.repo.On("Create", ctx, mock.Anything).Return(errors.New("db down")).Once()
+		_, err := s.SubmitKYC(ctx, submitRequest(user, ""))
+		assert.Error(t, err)
+		m.users.AssertNotCalled(t, "UpdateKYCStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestResubmitDocument_KeepsHistory(t *testing.T) {
+	s, m := newService()
+	user := uuid.New()
+	first := passport(user, domain.KYCDocumentInReview)
+
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusRejected).Return(nil).Once()
+	m.repo.On("GetByUserID", ctx, user).Return(docs(first), nil).Once()
+	decides(m, string(domain.KYCStatusRejected), "photo is blurred", first)
+	require.NoError(t, s.ReviewApplication(ctx, user, string(domain.KYCStatusRejected), "photo is blurred", reviewer))
+	m.assert(t)
+
+	reason := "photo is blurred"
+	first.ReviewStatus, first.RejectionReason = domain.KYCDocumentRejected, &reason
+	m.repo.On("GetByID", ctx, first.ID).Return(first, nil).Once()
+	m.repo.On("Resubmit", ctx, mock.MatchedBy(func(d *domain.KYCDocument) bool {
+		return d.ReplacesID != nil && *d.ReplacesID == first.ID && *d.FrontImageURL == "/uploads/kyc/new.png"
+	})).Return(nil).Once()
+	m.repo.On("SetReviewStatus", ctx, mock.Anything, domain.KYCDocumentInReview, (*string)(nil)).Return(nil).Once()
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusPending).Return(nil).Once()
+	second, err := s.ResubmitDocument(ctx, &ResubmitDocumentRequest{UserID: user, DocumentID: first.ID, FrontImageURL: "/uploads/kyc/new.png"})
+	require.NoError(t, err)
+	assert.Equal(t, "P1", *second.DocumentNumber, "the details carry over")
+	assert.Equal(t, domain.KYCDocumentInReview, second.ReviewStatus)
+	m.assert(t)
+
+	first.SupersededAt = &second.CreatedAt
+	m.users.On("FindByID", ctx, user).Return(&domain.User{ID: user, KYCStatus: domain.KYCStatusPending}, nil).Once()
+	m.repo.On("GetByUserID", ctx, user).Return(docs(second, first), nil).Once()
+	status, err := s.GetKYCStatus(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCStatusPending, status.Status, "a re-upload reopens the application")
+	require.Len(t, status.Documents, 1)
+	current := status.Documents[0]
+	assert.Equal(t, second.ID, current.ID)
+	assert.Equal(t, domain.KYCDocumentInReview, current.Status)
+	require.Len(t, current.Attempts, 1)
+	assert.Equal(t, first.ID, current.Attempts[0].ID)
+	assert.Equal(t, domain.KYCDocumentRejected, current.Attempts[0].Status)
+	assert.Equal(t, "photo is blurred", *current.Attempts[0].RejectionReason)
+	assert.NotNil(t, current.Attempts[0].ReplacedAt)
+
+	// Approving the application decides only the current attempt.
+	m.repo.On("GetByUserID", ctx, user).Return(docs(second, first), nil).Twice()
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusVerified).Return(nil).Once()
+	decides(m, string(domain.KYCStatusVerified), "", second)
+	require.NoError(t, s.ReviewApplication(ctx, user, string(domain.KYCStatusVerified), "", reviewer))
+	m.assert(t)
+	m.repo.AssertNotCalled(t, "SetReviewStatus", ctx, first.ID, domain.KYCDocumentAccepted, mock.Anything)
+}
+
+func TestResubmitDocument_Rejections(t *testing.T) {
+	user := uuid.New()
+	replaced := passport(user, domain.KYCDocumentRejected)
+	replaced.SupersededAt = &replaced.CreatedAt
+	tests := []struct {
+		name string
+		user uuid.UUID
+		doc  *domain.KYCDocument
+		err  error
+	}{
+		{"another user's document", uuid.New(), passport(user, domain.KYCDocumentRejected), ErrDocumentNotFound},
+		{"missing", user, nil, ErrDocumentNotFound},
+		{"already replaced", user, replaced, ErrDocumentReplaced},
+		{"accepted", user, passport(user, domain.KYCDocumentAccepted), ErrDocumentNotReplaced},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newService()
+			id := uuid.New()
+			if tt.doc != nil {
+				id = tt.doc.ID
+				m.repo.On("GetByID", ctx, id).Return(tt.doc, nil).Once()
+			} else {
+				m.repo.On("GetByID", ctx, id).Return(nil, ErrDocumentNotFound).Once()
+			}
+
+			_, err := s.ResubmitDocument(ctx, &ResubmitDocumentRequest{UserID: tt.user, DocumentID: id})
+			assert.ErrorIs(t, err, tt.err)
+			m.assert(t)
+			m.repo.AssertNotCalled(t, "Resubmit", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// flakyScanner fails while down and otherwise defers to virusscan.Mock.
+type flakyScanner struct{ down bool }
+
+func (f *flakyScanner) Scan(ctx context.Context, r io.Reader) (virusscan.Result, error) {
+	if f.down {
+		return virusscan.Result{}, errors.New("connect to clamd: connection refused")
+	}
+	return virusscan.Mock{}.Scan(ctx, r)
+}
+
+func newScanningService(t *testing.T) (*Service, *mocks, *flakyScanner, UploadDir) {
+	s, m := newService()
+	dir := t.TempDir()
+	files := UploadDir{Dir: filepath.Join(dir, "kyc"), QuarantineDir: filepath.Join(dir, "quarantine")}
+	require.NoError(t, os.MkdirAll(files.Dir, 0700))
+	scanner := &flakyScanner{}
+	return s.WithScanner(scanner, files), m, scanner, files
+}
+
+func upload(t *testing.T, files UploadDir, name, content string) string {
+	require.NoError(t, os.WriteFile(filepath.Join(files.Dir, name), []byte(content), 0600))
+	return "/uploads/kyc/" + name
+}
+
+// scans expects a new upload to be scanned to scan, then to go to review
+// status.
+func scans(m *mocks, scan domain.VirusScanStatus, signature *string, status domain.KYCDocumentStatus) {
+	m.repo.On("SetReviewStatus", ctx, mock.Anything, domain.KYCDocumentScanning, (*string)(nil)).Return(nil).Once()
+	m.repo.On("SetVirusScan", ctx, mock.Anything, scan, signature).Return(nil).Once()
+	if status != domain.KYCDocumentScanning {
+		m.repo.On("SetReviewStatus", ctx, mock.Anything, status, (*string)(nil)).Return(nil).Once()
+	}
+}
+
+func TestSubmitKYC_InfectedUploadIsQuarantined(t *testing.T) {
+	s, m, _, files := newScanningService(t)
+	user := uuid.New()
+
+	eicar := `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	url := upload(t, files, "bad.png", "header "+eicar)
+	m.repo.On("Create", ctx, mock.MatchedBy(func(d *domain.KYCDocument) bool {
+		return d.VirusScanStatus == domain.VirusScanPending
+	})).Return(nil).Once()
+	signature := "Eicar-Test-Signature"
+	scans(m, domain.VirusScanInfected, &signature, domain.KYCDocumentQuarantined)
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusPending).Return(nil).Once()
+	doc, err := s.SubmitKYC(ctx, submitRequest(user, url))
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCDocumentQuarantined, doc.ReviewStatus)
+	assert.Equal(t, domain.VirusScanInfected, doc.VirusScanStatus)
+	m.assert(t)
+
+	// The file is no longer where it is served from.
+	_, err = os.Stat(filepath.Join(files.Dir, "bad.png"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(files.QuarantineDir, "bad.png"))
+	assert.NoError(t, err)
+
+	m.repo.On("GetByUserID", ctx, user).Return(docs(doc), nil).Once()
+	assert.ErrorIs(t, s.ReviewApplication(ctx, user, string(domain.KYCStatusVerified), "", reviewer), ErrDocumentNotScanned)
+	m.repo.On("GetByID", ctx, doc.ID).Return(doc, nil).Once()
+	assert.ErrorIs(t, s.ReviewKYC(ctx, doc.ID, string(domain.KYCStatusVerified), "", reviewer), ErrDocumentNotScanned)
+	m.assert(t)
+	m.users.AssertNotCalled(t, "UpdateKYCStatus", ctx, user, domain.KYCStatusVerified)
+
+	// Rejecting is still allowed.
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusRejected).Return(nil).Once()
+	m.repo.On("GetByUserID", ctx, user).Return(docs(doc), nil).Once()
+	decides(m, string(domain.KYCStatusRejected), "file failed virus scan", doc)
+	require.NoError(t, s.ReviewApplication(ctx, user, string(domain.KYCStatusRejected), "file failed virus scan", reviewer))
+	m.assert(t)
+
+	// A clean replacement clears the way.
+	doc.ReviewStatus = domain.KYCDocumentRejected
+	m.repo.On("GetByID", ctx, doc.ID).Return(doc, nil).Once()
+	m.repo.On("Resubmit", ctx, mock.Anything).Return(nil).Once()
+	scans(m, domain.VirusScanClean, nil, domain.KYCDocumentInReview)
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusPending).Return(nil).Once()
+	clean, err := s.ResubmitDocument(ctx, &ResubmitDocumentRequest{UserID: user, DocumentID: doc.ID, FrontImageURL: upload(t, files, "good.png", "passport")})
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCDocumentInReview, clean.ReviewStatus)
+	assert.Equal(t, domain.VirusScanClean, clean.VirusScanStatus)
+	m.assert(t)
+
+	doc.SupersededAt = &clean.CreatedAt
+	m.repo.On("GetByUserID", ctx, user).Return(docs(clean, doc), nil).Twice()
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusVerified).Return(nil).Once()
+	decides(m, string(domain.KYCStatusVerified), "", clean)
+	require.NoError(t, s.ReviewApplication(ctx, user, string(domain.KYCStatusVerified), "", reviewer))
+	m.assert(t)
+}
+
+func TestSubmitKYC_UnscannedUploadWaitsForRescan(t *testing.T) {
+	s, m, scanner, files := newScanningService(t)
+	user := uuid.New()
+
+	scanner.down = true
+	m.repo.On("Create", ctx, mock.Anything).Return(nil).Once()
+	scans(m, domain.VirusScanFailed, nil, domain.KYCDocumentScanning)
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusPending).Return(nil).Once()
+	doc, err := s.SubmitKYC(ctx, submitRequest(user, upload(t, files, "p.png", strings.Repeat("x", 1024))))
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCDocumentScanning, doc.ReviewStatus)
+	assert.Equal(t, domain.VirusScanFailed, doc.VirusScanStatus)
+	m.repo.On("GetByUserID", ctx, user).Return(docs(doc), nil).Once()
+	assert.ErrorIs(t, s.ReviewApplication(ctx, user, string(domain.KYCStatusVerified), "", reviewer), ErrDocumentNotScanned)
+	m.assert(t)
+
+	scanner.down = false
+	m.repo.On("GetByID", ctx, doc.ID).Return(doc, nil).Once()
+	m.repo.On("SetVirusScan", ctx, doc.ID, domain.VirusScanClean, (*string)(nil)).Return(nil).Once()
+	m.repo.On("SetReviewStatus", ctx, doc.ID, domain.KYCDocumentInReview, (*string)(nil)).Return(nil).Once()
+	doc, err = s.RescanDocument(ctx, doc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCDocumentInReview, doc.ReviewStatus)
+	assert.Equal(t, domain.VirusScanClean, doc.VirusScanStatus)
+	m.assert(t)
+
+	m.repo.On("GetByID", ctx, doc.ID).Return(doc, nil).Once()
+	_, err = s.RescanDocument(ctx, doc.ID)
+	assert.ErrorIs(t, err, ErrScanNotNeeded)
+
+	m.repo.On("GetByUserID", ctx, user).Return(docs(doc), nil).Twice()
+	m.users.On("UpdateKYCStatus", ctx, user, domain.KYCStatusVerified).Return(nil).Once()
+	decides(m, string(domain.KYCStatusVerified), "", doc)
+	require.NoError(t, s.ReviewApplication(ctx, user, string(domain.KYCStatusVerified), "", reviewer))
+	m.assert(t)
+}
+
+func TestUploadDir_StaysInsideDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("x"), 0600))
+	files := UploadDir{Dir: filepath.Join(dir, "kyc")}
+	_, err := files.Open(context.Background(), "/uploads/kyc/../secret")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestScreening_SanctionsMatchBlocksVerificationUntilCleared(t *testing.T) {
+	s, m := newService()
+	screenings := &MockScreenings{}
+	s = s.WithScreening(aml.Mock{}, screenings)
+	user := &domain.User{ID: uuid.New(), FirstName: "Sanctioned Test", LastName: "Person", CountryCode: "MW"}
+	doc := passport(user.ID, domain.KYCDocumentInReview)
+	za := "ZA"
+	doc.IssuingCountry = &za
+
+	m.repo.On("Create", ctx, mock.Anything).Return(nil).Once()
+	m.repo.On("SetReviewStatus", ctx, mock.Anything, domain.KYCDocumentInReview, (*string)(nil)).Return(nil).Once()
+	m.users.On("UpdateKYCStatus", ctx, user.ID, domain.KYCStatusPending).Return(nil).Once()
+	m.users.On("FindByID", ctx, user.ID).Return(user, nil)
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(doc), nil)
+	var sc *domain.AMLScreening
+	screenings.On("CreateScreening", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		sc = args.Get(1).(*domain.AMLScreening)
+	}).Return(nil).Once()
+
+	_, err := s.SubmitKYC(ctx, &SubmitKYCRequest{UserID: user.ID, DocumentType: "passport", DocumentNumber: "P1", IssuingCountry: "ZA"})
+	require.NoError(t, err)
+	require.NotNil(t, sc, "applicants are screened on submission")
+	assert.True(t, sc.SanctionHit)
+	assert.Equal(t, 100, sc.RiskScore)
+	assert.ElementsMatch(t, []string{"MW", "ZA"}, []string(sc.Countries))
+
+	screenings.On("LatestScreening", ctx, user.ID).Return(sc, nil).Once()
+	assert.ErrorIs(t, s.ReviewApplication(ctx, user.ID, string(domain.KYCStatusVerified), "", reviewer), ErrSanctionsMatch)
+	m.users.AssertNotCalled(t, "UpdateKYCStatus", ctx, user.ID, domain.KYCStatusVerified)
+
+	_, err = s.ClearScreening(ctx, user.ID, " ", reviewer)
+	assert.Error(t, err, "a reason is required")
+	screenings.On("LatestScreening", ctx, user.ID).Return(sc, nil).Once()
+	screenings.On("ClearScreening", ctx, sc.ID, reviewer, "different date of birth").Return(true, nil).Once()
+	cleared, err := s.ClearScreening(ctx, user.ID, "different date of birth", reviewer)
+	require.NoError(t, err)
+	assert.False(t, cleared.BlocksVerification())
+	screenings.On("LatestScreening", ctx, user.ID).Return(sc, nil).Once()
+	screenings.On("ClearScreening", ctx, sc.ID, reviewer, "again").Return(false, nil).Once()
+	_, err = s.ClearScreening(ctx, user.ID, "again", reviewer)
+	assert.ErrorIs(t, err, ErrNothingToClear, "cleared meanwhile")
+	screenings.On("LatestScreening", ctx, user.ID).Return(cleared, nil).Once()
+	_, err = s.ClearScreening(ctx, user.ID, "again", reviewer)
+	assert.ErrorIs(t, err, ErrNothingToClear)
+	screenings.AssertExpectations(t)
+
+	screenings.On("LatestScreening", ctx, user.ID).Return(cleared, nil).Once()
+	m.users.On("UpdateKYCStatus", ctx, user.ID, domain.KYCStatusVerified).Return(nil).Once()
+	decides(m, string(domain.KYCStatusVerified), "", doc)
+	require.NoError(t, s.ReviewApplication(ctx, user.ID, string(domain.KYCStatusVerified), "", reviewer))
+	m.assert(t)
+	screenings.AssertExpectations(t)
+}
+
+func TestScreening_UnscreenedUserIsScreenedBeforeVerification(t *testing.T) {
+	s, m := newService()
+	screenings := &MockScreenings{}
+	s = s.WithScreening(aml.Mock{}, screenings)
+	user := &domain.User{ID: uuid.New(), FirstName: "Exposed Test", LastName: "Person"}
+	doc := passport(user.ID, domain.KYCDocumentInReview)
+
+	m.users.On("FindByID", ctx, user.ID).Return(user, nil).Once()
+	m.repo.On("GetByUserID", ctx, user.ID).Return(docs(doc), nil)
+	screenings.On("LatestScreening", ctx, user.ID).Return(nil, nil).Once()
+	var sc *domain.AMLScreening
+	screenings.On("CreateScreening", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		sc = args.Get(1).(*domain.AMLScreening)
+	}).Return(nil).Once()
+	m.users.On("UpdateKYCStatus", ctx, user.ID, domain.KYCStatusVerified).Return(nil).Once()
+	decides(m, string(domain.KYCStatusVerified), "", doc)
+
+	// A PEP is flagged for review but may still be verified.
+	require.NoError(t, s.ReviewApplication(ctx, user.ID, string(domain.KYCStatusVerified), "", reviewer))
+	require.NotNil(t, sc)
+	assert.True(t, sc.PEP)
+	assert.False(t, sc.SanctionHit)
+	m.assert(t)
+	screenings.AssertExpectations(t)
+}
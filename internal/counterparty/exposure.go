@@ -0,0 +1,161 @@
+package counterparty
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// RateSource supplies the rates outstanding settlements are converted at
+// before being compared with an exposure limit. Satisfied by the forex
+// repository.
+type RateSource interface {
+	GetLatestRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error)
+}
+
+// WithRates lets exposure held in one currency count against a limit set in
+// another. Without it such exposure cannot be valued and Admit fails.
+func (s *Service) WithRates(r RateSource) *Service {
+	s.rates = r
+	return s
+}
+
+// WithExposureWarning sets the share of its limit at which a counterparty's
+// exposure is reported as a warning. The default is 0.8.
+func (s *Service) WithExposureWarning(ratio float64) *Service {
+	if ratio > 0 && ratio < 1 {
+		s.warnAt = decimal.NewFromFloat(ratio)
+	}
+	return s
+}
+
+// Exposures returns every counterparty's exposure, most utilised first.
+func (s *Service) Exposures(ctx context.Context) ([]*domain.CounterpartyExposure, error) {
+	all, err := s.repo.List(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	outstanding, err := s.outstanding(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*domain.CounterpartyExposure, 0, len(all))
+	for _, c := range all {
+		e, err := s.exposure(ctx, c, outstanding[c.ID])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		ui, uj := items[i].Utilisation, items[j].Utilisation
+		switch {
+		case ui != nil && uj != nil && !ui.Equal(*uj):
+			return ui.GreaterThan(*uj)
+		case (ui == nil) != (uj == nil):
+			return ui != nil
+		}
+		return items[i].Code < items[j].Code
+	})
+	return items, nil
+}
+
+func (s *Service) outstanding(ctx context.Context) (map[uuid.UUID][]*domain.CounterpartyOutstanding, error) {
+	rows, err := s.repo.Outstanding(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID][]*domain.CounterpartyOutstanding)
+	for _, o := range rows {
+		byID[o.CounterpartyID] = append(byID[o.CounterpartyID], o)
+	}
+	return byID, nil
+}
+
+// exposure values what is outstanding with c. Without a limit there is no
+// currency to value it in, so Exposure is only set when it is all in one.
+func (s *Service) exposure(ctx context.Context, c *domain.Counterparty, outstanding []*domain.CounterpartyOutstanding) (*domain.CounterpartyExposure, error) {
+	e := &domain.CounterpartyExposure{
+		CounterpartyID: c.ID,
+		Code:           c.Code,
+		Name:           c.Name,
+		Status:         c.Status,
+		Outstanding:    outstanding,
+		Limit:          c.ExposureLimit,
+		Level:          domain.ExposureOK,
+	}
+	if e.Outstanding == nil {
+		e.Outstanding = []*domain.CounterpartyOutstanding{}
+	}
+	for _, o := range outstanding {
+		e.Settlements += o.Settlements
+	}
+
+	if c.ExposureLimit == nil {
+		if len(outstanding) == 1 {
+			amount := outstanding[0].Amount
+			e.Currency, e.Exposure = outstanding[0].Currency, &amount
+		}
+		return e, nil
+	}
+
+	total := decimal.Zero
+	for _, o := range outstanding {
+		v, err := s.convert(ctx, o.Amount, o.Currency, c.ExposureCurrency)
+		if err != nil {
+			return nil, err
+		}
+		total = total.Add(v)
+	}
+	utilisation := total.Div(*c.ExposureLimit)
+	switch {
+	case utilisation.GreaterThanOrEqual(decimal.NewFromInt(1)):
+		e.Level = domain.ExposureBreached
+	case utilisation.GreaterThanOrEqual(s.warnAt):
+		e.Level = domain.ExposureWarning
+	}
+	utilisation = utilisation.Round(4)
+	e.Currency, e.Exposure, e.Utilisation = c.ExposureCurrency, &total, &utilisation
+	return e, nil
+}
+
+// withinLimit reports whether set can go to c without taking its exposure
+// past its limit.
+func (s *Service) withinLimit(ctx context.Context, c *domain.Counterparty, outstanding []*domain.CounterpartyOutstanding, set *domain.Settlement) (bool, error) {
+	e, err := s.exposure(ctx, c, outstanding)
+	if err != nil {
+		return false, err
+	}
+	amount, err := s.convert(ctx, set.TotalAmount, set.Currency, c.ExposureCurrency)
+	if err != nil {
+		return false, err
+	}
+	return !e.Exposure.Add(amount).GreaterThan(*c.ExposureLimit), nil
+}
+
+// convert values amount in another currency at the latest stored rate,
+// using the inverse pair when only that is quoted.
+func (s *Service) convert(ctx context.Context, amount decimal.Decimal, from, to domain.Currency) (decimal.Decimal, error) {
+	if from == to {
+		return amount, nil
+	}
+	if s.rates == nil {
+		return decimal.Zero, fmt.Errorf("no rates to value %s exposure in %s", from, to)
+	}
+	if rate, err := s.rates.GetLatestRate(ctx, from, to); err == nil && rate.Rate.IsPositive() {
+		return amount.Mul(rate.Rate), nil
+	}
+	rate, err := s.rates.GetLatestRate(ctx, to, from)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("no %s/%s rate to value exposure: %w", from, to, err)
+	}
+	if !rate.Rate.IsPositive() {
+		return decimal.Zero, fmt.Errorf("no %s/%s rate to value exposure", from, to)
+	}
+	return amount.Div(rate.Rate), nil
+}
@@ -0,0 +1,475 @@
+// Package counterparty manages the settlement partners, payout providers and
+// correspondent banks settlements are paid out through: their contacts and
+// compliance documents, the corridors they serve, their exposure limits and
+// prefunded balances, and whether they are active.
+//
+// The settlement service asks Admit before each submission. A corridor no
+// counterparty serves settles as before; one whose counterparties are all
+// suspended, at their exposure limits, or short of prefunding for the
+// settlement, waits.
+package counterparty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrCounterpartyNotFound   = errors.New("counterparty not found")
+	ErrInvalidCounterparty    = errors.New("invalid counterparty")
+	ErrCounterpartyExists     = errors.New("counterparty code already in use")
+	ErrCounterpartyInUse      = errors.New("counterparty has prefunding history; suspend it instead")
+	ErrDocumentNotFound       = errors.New("counterparty document not found")
+	ErrInvalidPrefunding      = errors.New("invalid prefunding")
+	ErrInsufficientPrefunding = errors.New("insufficient prefunding")
+)
+
+// Settlement metadata keys recording the counterparty a settlement went to
+// and whether its prefunding has been drawn.
+const (
+	CounterpartyIDKey   = "counterparty_id"
+	CounterpartyCodeKey = "counterparty_code"
+	PrefundingDrawnKey  = "prefunding_drawn"
+)
+
+// Repository persists counterparties, their documents and prefunding.
+type Repository interface {
+	// Create returns ErrCounterpartyExists when the code is taken, as does
+	// Update.
+	Create(ctx context.Context, c *domain.Counterparty) error
+	Update(ctx context.Context, c *domain.Counterparty) error
+	Get(ctx context.Context, id uuid.UUID) (*domain.Counterparty, error)
+	// List filters by status and type when they are non-empty.
+	List(ctx context.Context, status, cpType string) ([]*domain.Counterparty, error)
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+
+	AddDocument(ctx context.Context, d *domain.CounterpartyDocument) error
+	ListDocuments(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyDocument, error)
+	DeleteDocument(ctx context.Context, counterpartyID, id uuid.UUID) (bool, error)
+
+	Balances(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyBalance, error)
+	// ApplyPrefunding moves the balance by e.Amount and records e with the
+	// balance that results. It reports false, recording nothing, when the
+	// balance would go negative.
+	ApplyPrefunding(ctx context.Context, e *domain.PrefundingEntry) (bool, error)
+	ListPrefunding(ctx context.Context, counterpartyID uuid.UUID, limit, offset int) ([]*domain.PrefundingEntry, int, error)
+
+	// Outstanding sums, per counterparty and currency, the settlements
+	// that have drawn prefunding and not yet completed.
+	Outstanding(ctx context.Context) ([]*domain.CounterpartyOutstanding, error)
+}
+
+type Service struct {
+	repo   Repository
+	rates  RateSource
+	warnAt decimal.Decimal
+	logger logger.Logger
+}
+
+func NewService(repo Repository, log logger.Logger) *Service {
+	return &Service{repo: repo, warnAt: decimal.NewFromFloat(0.8), logger: log}
+}
+
+// CounterpartyRequest creates a counterparty or replaces its details.
+type CounterpartyRequest struct {
+	Code             string                       `json:"code"`
+	Name             string                       `json:"name"`
+	Type             domain.CounterpartyType      `json:"type"`
+	Country          string                       `json:"country"`
+	Corridors        []string                     `json:"corridors"`
+	ExposureLimit    *decimal.Decimal             `json:"exposure_limit"`
+	ExposureCurrency domain.Currency              `json:"exposure_currency"`
+	Contacts         []domain.CounterpartyContact `json:"contacts"`
+	Notes            string                       `json:"notes"`
+}
+
+var (
+	codePattern     = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{1,31}$`)
+	corridorPattern = regexp.MustCompile(`^[A-Z]{3}-[A-Z]{3}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidCounterparty, fmt.Sprintf(format, args...))
+}
+
+// apply validates req and copies it onto c.
+func apply(c *domain.Counterparty, req CounterpartyRequest) error {
+	c.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	if !codePattern.MatchString(c.Code) {
+		return invalid("code must be 2-32 letters, digits, - or _")
+	}
+	c.Name = strings.TrimSpace(req.Name)
+	if c.Name == "" {
+		return invalid("name is required")
+	}
+	switch req.Type {
+	case domain.CounterpartySettlementPartner, domain.CounterpartyPayoutProvider, domain.CounterpartyCorrespondentBank:
+		c.Type = req.Type
+	default:
+		return invalid("type must be settlement_partner, payout_provider or correspondent_bank")
+	}
+	c.Country = strings.ToUpper(strings.TrimSpace(req.Country))
+	if len(c.Country) != 2 {
+		return invalid("country must be an ISO 3166 alpha-2 code")
+	}
+
+	c.Corridors = c.Corridors[:0]
+	seen := map[string]bool{}
+	for _, corridor := range req.Corridors {
+		corridor = strings.ToUpper(strings.TrimSpace(corridor))
+		if !corridorPattern.MatchString(corridor) {
+			return invalid("corridor %q must look like MWK-ZAR", corridor)
+		}
+		if !seen[corridor] {
+			seen[corridor] = true
+			c.Corridors = append(c.Corridors, corridor)
+		}
+	}
+	sort.Strings(c.Corridors)
+
+	c.ExposureLimit, c.ExposureCurrency = nil, ""
+	if req.ExposureLimit != nil {
+		if !req.ExposureLimit.IsPositive() {
+			return invalid("exposure_limit must be positive")
+		}
+		cur := domain.Currency(strings.ToUpper(string(req.ExposureCurrency)))
+		if !currencyPattern.MatchString(string(cur)) {
+			return invalid("exposure_currency is required with exposure_limit")
+		}
+		limit := *req.ExposureLimit
+		c.ExposureLimit, c.ExposureCurrency = &limit, cur
+	}
+
+	c.Contacts = domain.CounterpartyContacts{}
+	for _, ct := range req.Contacts {
+		ct.Name, ct.Email, ct.Phone = strings.TrimSpace(ct.Name), strings.TrimSpace(ct.Email), strings.TrimSpace(ct.Phone)
+		if ct.Name == "" || (ct.Email == "" && ct.Phone == "") {
+			return invalid("each contact needs a name and an email or phone")
+		}
+		c.Contacts = append(c.Contacts, ct)
+	}
+	c.Notes = strings.TrimSpace(req.Notes)
+	return nil
+}
+
+// Create adds a counterparty. New counterparties start active.
+func (s *Service) Create(ctx context.Context, req CounterpartyRequest, createdBy uuid.UUID) (*domain.Counterparty, error) {
+	now := time.Now().UTC()
+	c := &domain.Counterparty{ID: uuid.New(), Status: domain.CounterpartyActive, CreatedBy: &createdBy, CreatedAt: now, UpdatedAt: now}
+	if err := apply(c, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update replaces a counterparty's details. Its status and prefunding are
+// changed through SetStatus and Prefund.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, req CounterpartyRequest) (*domain.Counterparty, error) {
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(c, req); err != nil {
+		return nil, err
+	}
+	c.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.Counterparty, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *Service) List(ctx context.Context, status, cpType string) ([]*domain.Counterparty, error) {
+	return s.repo.List(ctx, status, cpType)
+}
+
+// Delete removes a counterparty that never held prefunding. One that has
+// is kept for its history and should be suspended instead.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return err
+	}
+	_, total, err := s.repo.ListPrefunding(ctx, id, 1, 0)
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return ErrCounterpartyInUse
+	}
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCounterpartyNotFound
+	}
+	return nil
+}
+
+// SetStatus suspends or reactivates a counterparty. Settlements waiting on
+// a suspended counterparty go out on the first run after it is reactivated.
+func (s *Service) SetStatus(ctx context.Context, id uuid.UUID, status domain.CounterpartyStatus, reason string) (*domain.Counterparty, error) {
+	if status != domain.CounterpartyActive && status != domain.CounterpartySuspended {
+		return nil, invalid("status must be active or suspended")
+	}
+	reason = strings.TrimSpace(reason)
+	if status == domain.CounterpartySuspended && reason == "" {
+		return nil, invalid("a reason is required to suspend")
+	}
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.Status, c.StatusReason, c.UpdatedAt = status, reason, time.Now().UTC()
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Counterparty status changed", map[string]interface{}{
+		"counterparty_id": c.ID,
+		"code":            c.Code,
+		"status":          status,
+		"reason":          reason,
+	})
+	return c, nil
+}
+
+// DocumentRequest records a contract, licence or compliance document.
+type DocumentRequest struct {
+	Kind      string     `json:"kind"`
+	Name      string     `json:"name"`
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (s *Service) AddDocument(ctx context.Context, counterpartyID uuid.UUID, req DocumentRequest, uploadedBy uuid.UUID) (*domain.CounterpartyDocument, error) {
+	if _, err := s.repo.Get(ctx, counterpartyID); err != nil {
+		return nil, err
+	}
+	d := &domain.CounterpartyDocument{
+		ID:             uuid.New(),
+		CounterpartyID: counterpartyID,
+		Kind:           strings.ToLower(strings.TrimSpace(req.Kind)),
+		Name:           strings.TrimSpace(req.Name),
+		URL:            strings.TrimSpace(req.URL),
+		ExpiresAt:      req.ExpiresAt,
+		UploadedBy:     &uploadedBy,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if d.Kind == "" || d.Name == "" {
+		return nil, invalid("document kind and name are required")
+	}
+	if !strings.HasPrefix(d.URL, "https://") && !strings.HasPrefix(d.URL, "s3://") {
+		return nil, invalid("document url must be https:// or s3://")
+	}
+	if err := s.repo.AddDocument(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *Service) ListDocuments(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyDocument, error) {
+	if _, err := s.repo.Get(ctx, counterpartyID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDocuments(ctx, counterpartyID)
+}
+
+func (s *Service) DeleteDocument(ctx context.Context, counterpartyID, id uuid.UUID) error {
+	deleted, err := s.repo.DeleteDocument(ctx, counterpartyID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDocumentNotFound
+	}
+	return nil
+}
+
+// PrefundingRequest records funding a counterparty sent us (deposit) or
+// funding we sent back (withdrawal).
+type PrefundingRequest struct {
+	Kind      domain.PrefundingEntryKind `json:"kind"`
+	Currency  domain.Currency            `json:"currency"`
+	Amount    decimal.Decimal            `json:"amount"`
+	Reference string                     `json:"reference"`
+}
+
+func (s *Service) Prefund(ctx context.Context, counterpartyID uuid.UUID, req PrefundingRequest, createdBy uuid.UUID) (*domain.PrefundingEntry, error) {
+	if _, err := s.repo.Get(ctx, counterpartyID); err != nil {
+		return nil, err
+	}
+	cur := domain.Currency(strings.ToUpper(string(req.Currency)))
+	if !currencyPattern.MatchString(string(cur)) {
+		return nil, fmt.Errorf("%w: currency is required", ErrInvalidPrefunding)
+	}
+	if !req.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidPrefunding)
+	}
+	amount := req.Amount
+	switch req.Kind {
+	case domain.PrefundingDeposit:
+	case domain.PrefundingWithdrawal:
+		amount = amount.Neg()
+	default:
+		return nil, fmt.Errorf("%w: kind must be deposit or withdrawal", ErrInvalidPrefunding)
+	}
+	if strings.TrimSpace(req.Reference) == "" {
+		return nil, fmt.Errorf("%w: reference is required", ErrInvalidPrefunding)
+	}
+
+	e := &domain.PrefundingEntry{
+		ID:             uuid.New(),
+		CounterpartyID: counterpartyID,
+		Currency:       cur,
+		Kind:           req.Kind,
+		Amount:         amount,
+		Reference:      strings.TrimSpace(req.Reference),
+		CreatedBy:      &createdBy,
+		CreatedAt:      time.Now().UTC(),
+	}
+	ok, err := s.repo.ApplyPrefunding(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInsufficientPrefunding
+	}
+	return e, nil
+}
+
+func (s *Service) Balances(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyBalance, error) {
+	if _, err := s.repo.Get(ctx, counterpartyID); err != nil {
+		return nil, err
+	}
+	return s.repo.Balances(ctx, counterpartyID)
+}
+
+func (s *Service) ListPrefunding(ctx context.Context, counterpartyID uuid.UUID, limit, offset int) ([]*domain.PrefundingEntry, int, error) {
+	return s.repo.ListPrefunding(ctx, counterpartyID, limit, offset)
+}
+
+// Admit picks the counterparty a settlement is paid out through and draws
+// the settlement amount from its prefunding. Counterparties the settlement
+// would take past their exposure limit are passed over. It returns the
+// reason the settlement must wait, or "" to go ahead. Corridors no
+// counterparty serves go ahead untouched.
+func (s *Service) Admit(ctx context.Context, set *domain.Settlement) (string, error) {
+	if drawn, _ := set.Metadata[PrefundingDrawnKey].(bool); drawn {
+		return "", nil
+	}
+	corridor, _ := set.Metadata["corridor"].(string)
+	all, err := s.repo.List(ctx, "", "")
+	if err != nil {
+		return "", err
+	}
+	var serving, active []*domain.Counterparty
+	for _, c := range all {
+		if !c.Serves(corridor) {
+			continue
+		}
+		serving = append(serving, c)
+		if c.Status == domain.CounterpartyActive {
+			active = append(active, c)
+		}
+	}
+	if len(serving) == 0 {
+		return "", nil
+	}
+	if len(active) == 0 {
+		return fmt.Sprintf("every counterparty for %s is suspended", corridor), nil
+	}
+
+	sort.Slice(active, func(i, j int) bool { return active[i].Code < active[j].Code })
+	var outstanding map[uuid.UUID][]*domain.CounterpartyOutstanding
+	limited := 0
+	for _, c := range active {
+		if c.ExposureLimit != nil {
+			if outstanding == nil {
+				if outstanding, err = s.outstanding(ctx); err != nil {
+					return "", err
+				}
+			}
+			within, err := s.withinLimit(ctx, c, outstanding[c.ID], set)
+			if err != nil {
+				return "", err
+			}
+			if !within {
+				limited++
+				continue
+			}
+		}
+		ok, err := s.repo.ApplyPrefunding(ctx, s.settlementEntry(c.ID, set, domain.PrefundingDrawdown))
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
+		}
+		if set.Metadata == nil {
+			set.Metadata = make(domain.Metadata)
+		}
+		set.Metadata[CounterpartyIDKey] = c.ID.String()
+		set.Metadata[CounterpartyCodeKey] = c.Code
+		set.Metadata[PrefundingDrawnKey] = true
+		return "", nil
+	}
+	if limited == len(active) {
+		return fmt.Sprintf("every counterparty for %s is at its exposure limit", corridor), nil
+	}
+	return fmt.Sprintf("prefunding for %s does not cover %s %s", corridor, set.TotalAmount, set.Currency), nil
+}
+
+// Return gives back the prefunding Admit drew for a settlement that could
+// not be submitted, so the next attempt draws it again.
+func (s *Service) Return(ctx context.Context, set *domain.Settlement) error {
+	if drawn, _ := set.Metadata[PrefundingDrawnKey].(bool); !drawn {
+		return nil
+	}
+	idStr, _ := set.Metadata[CounterpartyIDKey].(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fmt.Errorf("settlement %s has no counterparty to return prefunding to", set.ID)
+	}
+	if _, err := s.repo.ApplyPrefunding(ctx, s.settlementEntry(id, set, domain.PrefundingReturn)); err != nil {
+		return err
+	}
+	delete(set.Metadata, PrefundingDrawnKey)
+	return nil
+}
+
+func (s *Service) settlementEntry(counterpartyID uuid.UUID, set *domain.Settlement, kind domain.PrefundingEntryKind) *domain.PrefundingEntry {
+	amount := set.TotalAmount
+	if kind == domain.PrefundingDrawdown {
+		amount = amount.Neg()
+	}
+	settlementID := set.ID
+	return &domain.PrefundingEntry{
+		ID:             uuid.New(),
+		CounterpartyID: counterpartyID,
+		Currency:       set.Currency,
+		Kind:           kind,
+		Amount:         amount,
+		Reference:      set.BatchReference,
+		SettlementID:   &settlementID,
+		CreatedAt:      time.Now().UTC(),
+	}
+}
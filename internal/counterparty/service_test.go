@@ -0,0 +1,415 @@
+package counterparty
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, c *domain.Counterparty) error {
+	return m.Called(ctx, c).Error(0)
+}
+
+func (m *MockRepository) Update(ctx context.Context, c *domain.Counterparty) error {
+	return m.Called(ctx, c).Error(0)
+}
+
+func (m *MockRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Counterparty, error) {
+	args := m.Called(ctx, id)
+	c, _ := args.Get(0).(*domain.Counterparty)
+	if c == nil {
+		return nil, args.Error(1)
+	}
+	cp := *c
+	return &cp, args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, status, cpType string) ([]*domain.Counterparty, error) {
+	args := m.Called(ctx, status, cpType)
+	items, _ := args.Get(0).([]*domain.Counterparty)
+	return items, args.Error(1)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) AddDocument(ctx context.Context, d *domain.CounterpartyDocument) error {
+	return m.Called(ctx, d).Error(0)
+}
+
+func (m *MockRepository) ListDocuments(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyDocument, error) {
+	args := m.Called(ctx, counterpartyID)
+	docs, _ := args.Get(0).([]*domain.CounterpartyDocument)
+	return docs, args.Error(1)
+}
+
+func (m *MockRepository) DeleteDocument(ctx context.Context, counterpartyID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, counterpartyID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Balances(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyBalance, error) {
+	args := m.Called(ctx, counterpartyID)
+	balances, _ := args.Get(0).([]*domain.CounterpartyBalance)
+	return balances, args.Error(1)
+}
+
+func (m *MockRepository) ApplyPrefunding(ctx context.Context, e *domain.PrefundingEntry) (bool, error) {
+	args := m.Called(ctx, e)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ListPrefunding(ctx context.Context, counterpartyID uuid.UUID, limit, offset int) ([]*domain.PrefundingEntry, int, error) {
+	args := m.Called(ctx, counterpartyID, limit, offset)
+	entries, _ := args.Get(0).([]*domain.PrefundingEntry)
+	return entries, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) Outstanding(ctx context.Context) ([]*domain.CounterpartyOutstanding, error) {
+	args := m.Called(ctx)
+	items, _ := args.Get(0).([]*domain.CounterpartyOutstanding)
+	return items, args.Error(1)
+}
+
+type MockRates struct {
+	mock.Mock
+}
+
+func (m *MockRates) GetLatestRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
+	args := m.Called(ctx, from, to)
+	rate, _ := args.Get(0).(*domain.ExchangeRate)
+	return rate, args.Error(1)
+}
+
+func rate(from, to domain.Currency, r string) *domain.ExchangeRate {
+	return &domain.ExchangeRate{BaseCurrency: from, TargetCurrency: to, Rate: decimal.RequireFromString(r)}
+}
+
+var ctx = context.Background()
+
+func newService() (*Service, *MockRepository) {
+	repo := new(MockRepository)
+	return NewService(repo, logger.NewNop()), repo
+}
+
+func validRequest(code string, corridors ...string) CounterpartyRequest {
+	return CounterpartyRequest{
+		Code:      code,
+		Name:      "Partner " + code,
+		Type:      domain.CounterpartyPayoutProvider,
+		Country:   "za",
+		Corridors: corridors,
+		Contacts:  []domain.CounterpartyContact{{Name: "Ops desk", Email: "ops@example.com"}},
+	}
+}
+
+// active is a stored, active counterparty serving corridors.
+func active(code string, corridors ...string) *domain.Counterparty {
+	return &domain.Counterparty{ID: uuid.New(), Code: code, Name: "Partner " + code, Status: domain.CounterpartyActive, Corridors: corridors}
+}
+
+// entry matches a prefunding entry of kind moving c's balance by amount.
+func entry(c *domain.Counterparty, kind domain.PrefundingEntryKind, amount int64) interface{} {
+	return mock.MatchedBy(func(e *domain.PrefundingEntry) bool {
+		return e.CounterpartyID == c.ID && e.Kind == kind && e.Amount.Equal(decimal.NewFromInt(amount))
+	})
+}
+
+func settlement(corridor string, amount int64) *domain.Settlement {
+	return &domain.Settlement{ID: uuid.New(), BatchReference: "SET-1", TotalAmount: decimal.NewFromInt(amount), Currency: domain.ZAR, Metadata: domain.Metadata{"corridor": corridor}}
+}
+
+func TestCreate_Validation(t *testing.T) {
+	s, repo := newService()
+	createdBy := uuid.New()
+	repo.On("Create", ctx, mock.MatchedBy(func(c *domain.Counterparty) bool {
+		return c.Code == "ZAR-PAY" && *c.CreatedBy == createdBy
+	})).Return(nil).Once()
+
+	c, err := s.Create(ctx, validRequest("zar-pay", "mwk-zar", "MWK-ZAR"), createdBy)
+	require.NoError(t, err)
+	assert.Equal(t, "ZAR-PAY", c.Code)
+	assert.Equal(t, "ZA", c.Country)
+	assert.Equal(t, []string{"MWK-ZAR"}, []string(c.Corridors))
+	assert.Equal(t, domain.CounterpartyActive, c.Status)
+	repo.AssertExpectations(t)
+
+	repo.On("Create", ctx, mock.Anything).Return(ErrCounterpartyExists).Once()
+	_, err = s.Create(ctx, validRequest("ZAR-PAY"), createdBy)
+	assert.ErrorIs(t, err, ErrCounterpartyExists)
+
+	limit := decimal.NewFromInt(1000)
+	tests := []struct {
+		name   string
+		mutate func(*CounterpartyRequest)
+	}{
+		{"code", func(r *CounterpartyRequest) { r.Code = "x" }},
+		{"type", func(r *CounterpartyRequest) { r.Type = "broker" }},
+		{"corridor", func(r *CounterpartyRequest) { r.Corridors = []string{"MWKZAR"} }},
+		{"exposure without currency", func(r *CounterpartyRequest) { r.ExposureLimit = &limit }},
+		{"contact", func(r *CounterpartyRequest) { r.Contacts = []domain.CounterpartyContact{{Name: "No way to reach"}} }},
+		{"no name", func(r *CounterpartyRequest) { r.Name = " " }},
+		{"bad country", func(r *CounterpartyRequest) { r.Country = "ZAF" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newService()
+			req := validRequest("OTHER")
+			tt.mutate(&req)
+			_, err := s.Create(ctx, req, createdBy)
+			assert.ErrorIs(t, err, ErrInvalidCounterparty)
+			repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateKeepsStatus(t *testing.T) {
+	s, repo := newService()
+	c := active("ZAR-PAY", "MWK-ZAR")
+	c.Status, c.StatusReason = domain.CounterpartySuspended, "licence review"
+	req := validRequest("ZAR-PAY", "MWK-ZAR", "USD-ZAR")
+	repo.On("Get", ctx, c.ID).Return(c, nil).Once()
+	repo.On("Update", ctx, mock.MatchedBy(func(u *domain.Counterparty) bool {
+		return len(u.Corridors) == 2 && u.Status == domain.CounterpartySuspended && u.StatusReason == "licence review"
+	})).Return(nil).Once()
+
+	_, err := s.Update(ctx, c.ID, req)
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestSetStatus(t *testing.T) {
+	s, repo := newService()
+	c := active("ZAR-PAY")
+
+	_, err := s.SetStatus(ctx, c.ID, domain.CounterpartySuspended, " ")
+	assert.ErrorIs(t, err, ErrInvalidCounterparty, "suspending needs a reason")
+	_, err = s.SetStatus(ctx, c.ID, "closed", "gone")
+	assert.ErrorIs(t, err, ErrInvalidCounterparty)
+	repo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+
+	repo.On("Get", ctx, c.ID).Return(c, nil).Once()
+	repo.On("Update", ctx, mock.MatchedBy(func(u *domain.Counterparty) bool {
+		return u.Status == domain.CounterpartySuspended && u.StatusReason == "licence review"
+	})).Return(nil).Once()
+	suspended, err := s.SetStatus(ctx, c.ID, domain.CounterpartySuspended, " licence review ")
+	require.NoError(t, err)
+	assert.Equal(t, domain.CounterpartySuspended, suspended.Status)
+	repo.AssertExpectations(t)
+}
+
+func TestPrefund(t *testing.T) {
+	s, repo := newService()
+	c := active("ZAR-PAY", "MWK-ZAR")
+	repo.On("Get", ctx, c.ID).Return(c, nil)
+
+	repo.On("ApplyPrefunding", ctx, entry(c, domain.PrefundingWithdrawal, -5)).Return(false, nil).Once()
+	_, err := s.Prefund(ctx, c.ID, PrefundingRequest{Kind: domain.PrefundingWithdrawal, Currency: "ZAR", Amount: decimal.NewFromInt(5), Reference: "W1"}, uuid.New())
+	assert.ErrorIs(t, err, ErrInsufficientPrefunding)
+
+	for name, req := range map[string]PrefundingRequest{
+		"drawdowns are made by settlements only": {Kind: domain.PrefundingDrawdown, Currency: "ZAR", Amount: decimal.NewFromInt(5), Reference: "X"},
+		"no currency":                            {Kind: domain.PrefundingDeposit, Amount: decimal.NewFromInt(5), Reference: "X"},
+		"negative":                               {Kind: domain.PrefundingDeposit, Currency: "ZAR", Amount: decimal.NewFromInt(-5), Reference: "X"},
+		"no reference":                           {Kind: domain.PrefundingDeposit, Currency: "ZAR", Amount: decimal.NewFromInt(5), Reference: " "},
+	} {
+		_, err = s.Prefund(ctx, c.ID, req, uuid.New())
+		assert.ErrorIs(t, err, ErrInvalidPrefunding, name)
+	}
+
+	repo.On("ApplyPrefunding", ctx, mock.MatchedBy(func(e *domain.PrefundingEntry) bool {
+		return e.Kind == domain.PrefundingDeposit && e.Currency == domain.ZAR && e.Reference == "SWIFT-1"
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.PrefundingEntry).BalanceAfter = decimal.NewFromInt(500)
+	}).Return(true, nil).Once()
+	e, err := s.Prefund(ctx, c.ID, PrefundingRequest{Kind: domain.PrefundingDeposit, Currency: "zar", Amount: decimal.NewFromInt(500), Reference: " SWIFT-1 "}, uuid.New())
+	require.NoError(t, err)
+	assert.True(t, e.BalanceAfter.Equal(decimal.NewFromInt(500)))
+	repo.AssertExpectations(t)
+}
+
+func TestDelete(t *testing.T) {
+	s, repo := newService()
+	funded, fresh, gone := active("FUNDED"), active("NEW"), active("GONE")
+	for _, c := range []*domain.Counterparty{funded, fresh, gone} {
+		repo.On("Get", ctx, c.ID).Return(c, nil).Once()
+	}
+	repo.On("ListPrefunding", ctx, funded.ID, 1, 0).Return([]*domain.PrefundingEntry{{}}, 3, nil).Once()
+	repo.On("ListPrefunding", ctx, fresh.ID, 1, 0).Return(nil, 0, nil).Once()
+	repo.On("ListPrefunding", ctx, gone.ID, 1, 0).Return(nil, 0, nil).Once()
+	repo.On("Delete", ctx, fresh.ID).Return(true, nil).Once()
+	repo.On("Delete", ctx, gone.ID).Return(false, nil).Once()
+
+	assert.ErrorIs(t, s.Delete(ctx, funded.ID), ErrCounterpartyInUse)
+	require.NoError(t, s.Delete(ctx, fresh.ID))
+	assert.ErrorIs(t, s.Delete(ctx, gone.ID), ErrCounterpartyNotFound, "deleted in the meantime")
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Delete", ctx, funded.ID)
+}
+
+func TestAdmit(t *testing.T) {
+	s, repo := newService()
+	a, b := active("A-PAY", "MWK-ZAR"), active("B-PAY", "MWK-ZAR")
+	repo.On("List", ctx, "", "").Return([]*domain.Counterparty{b, a}, nil)
+
+	reason, err := s.Admit(ctx, settlement("MWK-CNY", 1000))
+	require.NoError(t, err)
+	assert.Empty(t, reason, "corridors without counterparties are not managed")
+	repo.AssertNotCalled(t, "ApplyPrefunding", mock.Anything, mock.Anything)
+
+	set := settlement("MWK-ZAR", 100)
+	repo.On("ApplyPrefunding", ctx, entry(a, domain.PrefundingDrawdown, -100)).Return(false, nil).Once()
+	repo.On("ApplyPrefunding", ctx, entry(b, domain.PrefundingDrawdown, -100)).Return(true, nil).Once()
+	reason, err = s.Admit(ctx, set)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, "B-PAY", set.Metadata[CounterpartyCodeKey], "A-PAY is tried first and cannot cover it")
+	assert.Equal(t, b.ID.String(), set.Metadata[CounterpartyIDKey])
+	repo.AssertExpectations(t)
+
+	reason, err = s.Admit(ctx, set)
+	require.NoError(t, err)
+	assert.Empty(t, reason, "drawn once per settlement")
+
+	repo.On("ApplyPrefunding", ctx, mock.MatchedBy(func(e *domain.PrefundingEntry) bool {
+		return e.CounterpartyID == b.ID && e.Kind == domain.PrefundingReturn && e.Amount.Equal(decimal.NewFromInt(100)) &&
+			*e.SettlementID == set.ID && e.Reference == "SET-1"
+	})).Return(true, nil).Once()
+	require.NoError(t, s.Return(ctx, set))
+	assert.Nil(t, set.Metadata[PrefundingDrawnKey])
+	require.NoError(t, s.Return(ctx, set), "returned once")
+	repo.AssertExpectations(t)
+
+	repo.On("ApplyPrefunding", ctx, mock.Anything).Return(false, nil).Twice()
+	reason, err = s.Admit(ctx, settlement("MWK-ZAR", 500))
+	require.NoError(t, err)
+	assert.Equal(t, "prefunding for MWK-ZAR does not cover 500 ZAR", reason)
+
+	t.Run("all suspended", func(t *testing.T) {
+		s, repo := newService()
+		suspended := active("A-PAY", "MWK-ZAR")
+		suspended.Status = domain.CounterpartySuspended
+		repo.On("List", ctx, "", "").Return([]*domain.Counterparty{suspended}, nil).Once()
+
+		reason, err := s.Admit(ctx, settlement("MWK-ZAR", 10))
+		require.NoError(t, err)
+		assert.Contains(t, reason, "suspended")
+		repo.AssertNotCalled(t, "ApplyPrefunding", mock.Anything, mock.Anything)
+	})
+
+	t.Run("a drawn settlement without a counterparty", func(t *testing.T) {
+		s, _ := newService()
+		set := settlement("MWK-ZAR", 10)
+		set.Metadata[PrefundingDrawnKey] = true
+		assert.Error(t, s.Return(ctx, set))
+	})
+}
+
+func TestAdmit_ExposureLimit(t *testing.T) {
+	s, repo := newService()
+	rates := new(MockRates)
+	s.WithRates(rates)
+	limit := decimal.NewFromInt(1000)
+	a, b := active("A-PAY", "MWK-ZAR"), active("B-PAY", "MWK-ZAR")
+	a.ExposureLimit, a.ExposureCurrency = &limit, domain.ZAR
+	repo.On("List", ctx, "", "").Return([]*domain.Counterparty{a, b}, nil)
+	// 500 ZAR and 20 USD (360 ZAR) are still outstanding with A-PAY.
+	repo.On("Outstanding", ctx).Return([]*domain.CounterpartyOutstanding{
+		{CounterpartyID: a.ID, Currency: domain.ZAR, Amount: decimal.NewFromInt(500), Settlements: 2},
+		{CounterpartyID: a.ID, Currency: domain.USD, Amount: decimal.NewFromInt(20), Settlements: 1},
+	}, nil)
+	rates.On("GetLatestRate", ctx, domain.USD, domain.ZAR).Return(rate(domain.USD, domain.ZAR, "18"), nil)
+
+	set := settlement("MWK-ZAR", 140)
+	repo.On("ApplyPrefunding", ctx, entry(a, domain.PrefundingDrawdown, -140)).Return(true, nil).Once()
+	reason, err := s.Admit(ctx, set)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, "A-PAY", set.Metadata[CounterpartyCodeKey], "exactly at the limit is allowed")
+
+	set = settlement("MWK-ZAR", 141)
+	repo.On("ApplyPrefunding", ctx, entry(b, domain.PrefundingDrawdown, -141)).Return(true, nil).Once()
+	reason, err = s.Admit(ctx, set)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, "B-PAY", set.Metadata[CounterpartyCodeKey], "A-PAY would pass its limit")
+	repo.AssertExpectations(t)
+
+	t.Run("every counterparty at its limit", func(t *testing.T) {
+		s, repo := newService()
+		s.WithRates(rates)
+		repo.On("List", ctx, "", "").Return([]*domain.Counterparty{a}, nil).Once()
+		repo.On("Outstanding", ctx).Return([]*domain.CounterpartyOutstanding{
+			{CounterpartyID: a.ID, Currency: domain.ZAR, Amount: decimal.NewFromInt(1000), Settlements: 4},
+		}, nil).Once()
+
+		reason, err := s.Admit(ctx, settlement("MWK-ZAR", 1))
+		require.NoError(t, err)
+		assert.Contains(t, reason, "exposure limit")
+		repo.AssertNotCalled(t, "ApplyPrefunding", mock.Anything, mock.Anything)
+	})
+
+	t.Run("exposure that cannot be valued is not ignored", func(t *testing.T) {
+		s := NewService(repo, logger.NewNop())
+		_, err := s.Admit(ctx, settlement("MWK-ZAR", 1))
+		assert.Error(t, err)
+	})
+}
+
+func TestExposures(t *testing.T) {
+	s, repo := newService()
+	rates := new(MockRates)
+	s.WithRates(rates).WithExposureWarning(0.75)
+	limited := func(code string, limit int64) *domain.Counterparty {
+		c := active(code, "MWK-ZAR")
+		l := decimal.NewFromInt(limit)
+		c.ExposureLimit, c.ExposureCurrency = &l, domain.USD
+		return c
+	}
+	low, high, full, none := limited("LOW", 1000), limited("HIGH", 100), limited("FULL", 50), active("NONE", "MWK-ZAR")
+	repo.On("List", ctx, "", "").Return([]*domain.Counterparty{low, high, full, none}, nil).Once()
+	repo.On("Outstanding", ctx).Return([]*domain.CounterpartyOutstanding{
+		{CounterpartyID: low.ID, Currency: domain.USD, Amount: decimal.NewFromInt(100), Settlements: 1},
+		{CounterpartyID: high.ID, Currency: domain.ZAR, Amount: decimal.NewFromInt(1600), Settlements: 3},
+		{CounterpartyID: full.ID, Currency: domain.USD, Amount: decimal.NewFromInt(50), Settlements: 1},
+		{CounterpartyID: none.ID, Currency: domain.ZAR, Amount: decimal.NewFromInt(900), Settlements: 2},
+	}, nil).Once()
+	// Only the inverse pair is quoted.
+	rates.On("GetLatestRate", ctx, domain.ZAR, domain.USD).Return(nil, errors.New("no rate")).Once()
+	rates.On("GetLatestRate", ctx, domain.USD, domain.ZAR).Return(rate(domain.USD, domain.ZAR, "20"), nil).Once()
+
+	items, err := s.Exposures(ctx)
+	require.NoError(t, err)
+	require.Len(t, items, 4)
+	var codes []string
+	for _, e := range items {
+		codes = append(codes, e.Code)
+	}
+	assert.Equal(t, []string{"FULL", "HIGH", "LOW", "NONE"}, codes)
+
+	assert.Equal(t, domain.ExposureBreached, items[0].Level)
+	assert.Equal(t, domain.ExposureWarning, items[1].Level)
+	assert.True(t, items[1].Exposure.Equal(decimal.NewFromInt(80)), "ZAR is valued in the limit's currency")
+	assert.Equal(t, "0.8", items[1].Utilisation.String())
+	assert.Equal(t, domain.ExposureOK, items[2].Level)
+	assert.Nil(t, items[3].Utilisation)
+	assert.Equal(t, domain.ZAR, items[3].Currency)
+	assert.Equal(t, 2, items[3].Settlements)
+	repo.AssertExpectations(t)
+	rates.AssertExpectations(t)
+}
@@ -0,0 +1,437 @@
+// Package credit runs overdraft facilities on customers' wallets.
+//
+// An admin gives a KYC-verified, low-risk customer a facility on one
+// wallet, letting payments take it below zero as far as the limit. Money
+// coming into an overdrawn wallet repays it first, as it lands. Interest
+// accrues daily on the overdrawn balance and is booked as receivable; once
+// incoming credits bring the wallet back above zero, the worker sweeps the
+// accrued interest from it. A customer who has owed anything for too long
+// is past due, then delinquent, and a delinquent facility is suspended.
+package credit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrFacilityNotFound = errors.New("credit facility not found")
+	ErrFacilityExists   = errors.New("wallet already has an open credit facility")
+	ErrNotEligible      = errors.New("customer is not eligible for credit")
+	ErrInvalidFacility  = errors.New("invalid credit facility")
+	ErrFacilityClosed   = errors.New("credit facility is closed")
+	ErrRunInProgress    = errors.New("a credit run is already in progress")
+)
+
+// runLimit bounds how many facilities one run reviews.
+const runLimit = 1000
+
+// daysPerYear converts annual rates to daily ones.
+var daysPerYear = decimal.NewFromInt(365)
+
+// Notification event types.
+const (
+	EventFacilityOpened = "CREDIT_FACILITY_OPENED"
+	EventPastDue        = "CREDIT_PAST_DUE"
+	EventDelinquent     = "CREDIT_DELINQUENT"
+)
+
+type Repository interface {
+	// CreateFacility saves f and sets its wallet's overdraft limit,
+	// atomically. It returns ErrFacilityExists when the wallet has an open
+	// facility.
+	CreateFacility(ctx context.Context, f *domain.CreditFacility) error
+	GetFacility(ctx context.Context, id uuid.UUID) (*domain.CreditFacility, error)
+	ListFacilities(ctx context.Context, filter domain.CreditFacilityFilter, limit, offset int) ([]domain.CreditFacility, int, error)
+	// FacilitiesToReview returns facilities that are open or still owed.
+	FacilitiesToReview(ctx context.Context, limit int) ([]domain.CreditFacility, error)
+	// UpdateFacility saves f's terms, status, delinquency and accrual date,
+	// leaving the interest totals to the ledger, and sets its wallet's
+	// overdraft limit, atomically. Only an active facility's wallet may
+	// overdraw further; the others keep a limit of what is overdrawn.
+	UpdateFacility(ctx context.Context, f *domain.CreditFacility) error
+	DelinquencyReport(ctx context.Context) ([]domain.CreditDelinquencyLine, error)
+}
+
+// Ledger books interest. Satisfied by ledger.Service.
+type Ledger interface {
+	AccrueCreditInterest(ctx context.Context, a *domain.CreditInterestAccrual) (bool, error)
+	CollectCreditInterest(ctx context.Context, c *domain.CreditInterestCollection) (bool, error)
+}
+
+type UserFinder interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// WalletFinder returns nil when the user has no wallet in currency.
+type WalletFinder interface {
+	FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error)
+}
+
+// Notifier tells customers about their facility.
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
+type Service struct {
+	repo     Repository
+	ledger   Ledger
+	users    UserFinder
+	wallets  WalletFinder
+	notifier Notifier
+	cfg      config.CreditConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	running  sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, ledger Ledger, users UserFinder, wallets WalletFinder, notifier Notifier, cfg config.CreditConfig, log logger.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		ledger:   ledger,
+		users:    users,
+		wallets:  wallets,
+		notifier: notifier,
+		cfg:      cfg,
+		logger:   log,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start reviews the open facilities every configured interval until Stop
+// is called.
+func (s *Service) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := s.Run(context.Background()); err != nil && !errors.Is(err, ErrRunInProgress) {
+					s.logger.Error("Credit run failed", map[string]interface{}{"error": err.Error()})
+				}
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// OpenRequest gives a customer an overdraft on their wallet in Currency.
+// AnnualRate is a fraction, 0.24 being 24% a year.
+type OpenRequest struct {
+	UserID     uuid.UUID       `json:"user_id" validate:"required"`
+	Currency   domain.Currency `json:"currency" validate:"required"`
+	Limit      decimal.Decimal `json:"limit"`
+	AnnualRate decimal.Decimal `json:"annual_rate"`
+}
+
+// Open gives an eligible customer a facility on their wallet in the
+// requested currency.
+func (s *Service) Open(ctx context.Context, req *OpenRequest, openedBy *uuid.UUID) (*domain.CreditFacility, error) {
+	if err := s.validateTerms(req.Limit, req.AnnualRate); err != nil {
+		return nil, err
+	}
+	user, err := s.users.FindByID(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("%w: unknown customer", ErrInvalidFacility)
+	}
+	if user.KYCStatus != domain.KYCStatusVerified {
+		return nil, fmt.Errorf("%w: identity is not verified", ErrNotEligible)
+	}
+	if user.RiskScore.GreaterThan(decimal.NewFromFloat(s.cfg.MaxRiskScore)) {
+		return nil, fmt.Errorf("%w: risk score %s is above %v", ErrNotEligible, user.RiskScore, s.cfg.MaxRiskScore)
+	}
+	wallet, err := s.wallets.FindByUserAndCurrency(ctx, req.UserID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, fmt.Errorf("%w: customer has no %s wallet", ErrInvalidFacility, req.Currency)
+	}
+	if wallet.Status != domain.WalletStatusActive {
+		return nil, fmt.Errorf("%w: wallet is %s", ErrNotEligible, wallet.Status)
+	}
+
+	now := s.now().UTC()
+	f := &domain.CreditFacility{
+		ID:             uuid.New(),
+		UserID:         req.UserID,
+		WalletID:       wallet.ID,
+		Currency:       wallet.Currency,
+		Limit:          req.Limit.Round(2),
+		AnnualRate:     req.AnnualRate,
+		Status:         domain.CreditFacilityActive,
+		Delinquency:    domain.CreditCurrent,
+		AccruedThrough: day(now),
+		OpenedBy:       openedBy,
+		OpenedAt:       now,
+		UpdatedAt:      now,
+		Balance:        wallet.AvailableBalance,
+	}
+	if err := s.repo.CreateFacility(ctx, f); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Credit facility opened", map[string]interface{}{
+		"facility_id": f.ID,
+		"user_id":     f.UserID,
+		"wallet_id":   f.WalletID,
+		"limit":       f.Limit.String(),
+		"annual_rate": f.AnnualRate.String(),
+	})
+	s.notify(ctx, f.UserID, EventFacilityOpened, map[string]interface{}{
+		"limit":       f.Limit.String(),
+		"currency":    f.Currency,
+		"annual_rate": f.AnnualRate.Mul(decimal.NewFromInt(100)).String(),
+	})
+	return f, nil
+}
+
+// UpdateRequest changes a facility's terms or status; nil fields stay as
+// they are.
+type UpdateRequest struct {
+	Limit      *decimal.Decimal             `json:"limit"`
+	AnnualRate *decimal.Decimal             `json:"annual_rate"`
+	Status     *domain.CreditFacilityStatus `json:"status"`
+}
+
+// Update changes a facility's limit, rate or status. Lowering the limit
+// below what is overdrawn stops further drawings until it is repaid. A
+// closed facility cannot be changed.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, req *UpdateRequest) (*domain.CreditFacility, error) {
+	f, err := s.repo.GetFacility(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if f.Status == domain.CreditFacilityClosed {
+		return nil, ErrFacilityClosed
+	}
+	limit, rate := f.Limit, f.AnnualRate
+	if req.Limit != nil {
+		limit = req.Limit.Round(2)
+	}
+	if req.AnnualRate != nil {
+		rate = *req.AnnualRate
+	}
+	if err := s.validateTerms(limit, rate); err != nil {
+		return nil, err
+	}
+	f.Limit, f.AnnualRate = limit, rate
+	now := s.now().UTC()
+	if req.Status != nil {
+		switch *req.Status {
+		case domain.CreditFacilityActive, domain.CreditFacilitySuspended:
+		case domain.CreditFacilityClosed:
+			f.ClosedAt = &now
+		default:
+			return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidFacility, *req.Status)
+		}
+		f.Status = *req.Status
+	}
+	f.UpdatedAt = now
+	if err := s.repo.UpdateFacility(ctx, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *Service) validateTerms(limit, rate decimal.Decimal) error {
+	if !limit.IsPositive() {
+		return fmt.Errorf("%w: limit must be positive", ErrInvalidFacility)
+	}
+	if s.cfg.MaxLimit > 0 && limit.GreaterThan(decimal.NewFromFloat(s.cfg.MaxLimit)) {
+		return fmt.Errorf("%w: limit is above the maximum of %v", ErrInvalidFacility, s.cfg.MaxLimit)
+	}
+	if rate.IsNegative() || rate.GreaterThan(decimal.NewFromInt(1)) {
+		return fmt.Errorf("%w: annual_rate must be between 0 and 1", ErrInvalidFacility)
+	}
+	return nil
+}
+
+// Facilities lists a customer's facilities, closed ones included.
+func (s *Service) Facilities(ctx context.Context, userID uuid.UUID) ([]domain.CreditFacility, error) {
+	items, _, err := s.repo.ListFacilities(ctx, domain.CreditFacilityFilter{UserID: &userID}, runLimit, 0)
+	return items, err
+}
+
+func (s *Service) List(ctx context.Context, filter domain.CreditFacilityFilter, limit, offset int) ([]domain.CreditFacility, int, error) {
+	return s.repo.ListFacilities(ctx, filter, limit, offset)
+}
+
+// DelinquencyReport sums what is owed on open or unpaid facilities by
+// delinquency state and currency.
+func (s *Service) DelinquencyReport(ctx context.Context) ([]domain.CreditDelinquencyLine, error) {
+	return s.repo.DelinquencyReport(ctx)
+}
+
+// Run accrues interest on overdrawn facilities, sweeps accrued interest
+// from wallets back in credit and updates each facility's delinquency.
+func (s *Service) Run(ctx context.Context) (*domain.CreditRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer s.running.Unlock()
+
+	facilities, err := s.repo.FacilitiesToReview(ctx, runLimit)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	run := &domain.CreditRun{Examined: len(facilities)}
+	for i := range facilities {
+		if err := s.review(ctx, &facilities[i], now, run); err != nil {
+			run.Failed++
+			s.logger.Error("Failed to review credit facility", map[string]interface{}{
+				"facility_id": facilities[i].ID,
+				"error":       err.Error(),
+			})
+		}
+	}
+	return run, nil
+}
+
+func (s *Service) review(ctx context.Context, f *domain.CreditFacility, now time.Time, run *domain.CreditRun) error {
+	// Interest for each whole day since the last accrual, on what is
+	// overdrawn now
+	today := day(now)
+	if f.AccruedThrough.Before(today) {
+		days := int(today.Sub(f.AccruedThrough).Hours() / 24)
+		interest := f.Overdrawn().Mul(f.AnnualRate).Mul(decimal.NewFromInt(int64(days))).Div(daysPerYear).Round(2)
+		if interest.IsPositive() {
+			a := &domain.CreditInterestAccrual{
+				ID:          uuid.New(),
+				FacilityID:  f.ID,
+				Currency:    f.Currency,
+				PeriodStart: f.AccruedThrough,
+				PeriodEnd:   today,
+				Overdrawn:   f.Overdrawn(),
+				AnnualRate:  f.AnnualRate,
+				Amount:      interest,
+				CreatedAt:   now,
+			}
+			accrued, err := s.ledger.AccrueCreditInterest(ctx, a)
+			if err != nil {
+				return err
+			}
+			if accrued {
+				f.AccruedInterest = f.AccruedInterest.Add(a.Amount)
+				run.Accrued++
+			}
+		}
+		f.AccruedThrough = today
+	}
+
+	// Credits have brought the wallet back above zero: take the interest
+	if f.Balance.IsPositive() && f.AccruedInterest.IsPositive() {
+		c := &domain.CreditInterestCollection{FacilityID: f.ID}
+		collected, err := s.ledger.CollectCreditInterest(ctx, c)
+		if err != nil {
+			return err
+		}
+		if collected {
+			f.AccruedInterest = f.AccruedInterest.Sub(c.Amount)
+			f.InterestCollected = f.InterestCollected.Add(c.Amount)
+			f.Balance = f.Balance.Sub(c.Amount)
+			run.Collected++
+			s.logger.Info("Overdraft interest collected", map[string]interface{}{
+				"facility_id":    f.ID,
+				"transaction_id": c.TransactionID,
+				"amount":         c.Amount.String(),
+			})
+		}
+	}
+
+	previous := f.Delinquency
+	if !f.Owed() {
+		f.OverdrawnSince = nil
+		f.Delinquency = domain.CreditCurrent
+	} else {
+		if f.OverdrawnSince == nil {
+			f.OverdrawnSince = &now
+		}
+		f.Delinquency = s.delinquency(now.Sub(*f.OverdrawnSince))
+	}
+	if f.Delinquency != previous {
+		switch f.Delinquency {
+		case domain.CreditPastDue:
+			run.PastDue++
+			s.notify(ctx, f.UserID, EventPastDue, s.owedData(f))
+		case domain.CreditDelinquent:
+			run.Delinquent++
+			if f.Status == domain.CreditFacilityActive {
+				f.Status = domain.CreditFacilitySuspended
+			}
+			s.notify(ctx, f.UserID, EventDelinquent, s.owedData(f))
+		}
+		s.logger.Info("Credit facility delinquency changed", map[string]interface{}{
+			"facility_id": f.ID,
+			"from":        previous,
+			"to":          f.Delinquency,
+		})
+	}
+	f.UpdatedAt = now
+	return s.repo.UpdateFacility(ctx, f)
+}
+
+// delinquency is the state of a facility owed for d.
+func (s *Service) delinquency(d time.Duration) domain.CreditDelinquency {
+	days := int(d.Hours() / 24)
+	switch {
+	case days >= s.cfg.DelinquentDays:
+		return domain.CreditDelinquent
+	case days >= s.cfg.PastDueDays:
+		return domain.CreditPastDue
+	}
+	return domain.CreditCurrent
+}
+
+func (s *Service) owedData(f *domain.CreditFacility) map[string]interface{} {
+	return map[string]interface{}{
+		"overdrawn":        f.Overdrawn().String(),
+		"accrued_interest": f.AccruedInterest.String(),
+		"currency":         f.Currency,
+	}
+}
+
+func (s *Service) notify(ctx context.Context, userID uuid.UUID, event string, data map[string]interface{}) {
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.Notify(ctx, userID, event, data); err != nil {
+		s.logger.Warn("Failed to send credit notification", map[string]interface{}{
+			"user_id": userID,
+			"event":   event,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// day is the start of t's day in UTC.
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,397 @@
+package credit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateFacility(ctx context.Context, f *domain.CreditFacility) error {
+	return m.Called(ctx, f).Error(0)
+}
+
+func (m *MockRepository) GetFacility(ctx context.Context, id uuid.UUID) (*domain.CreditFacility, error) {
+	args := m.Called(ctx, id)
+	f, _ := args.Get(0).(*domain.CreditFacility)
+	if f == nil {
+		return nil, args.Error(1)
+	}
+	copied := *f
+	return &copied, args.Error(1)
+}
+
+func (m *MockRepository) ListFacilities(ctx context.Context, filter domain.CreditFacilityFilter, limit, offset int) ([]domain.CreditFacility, int, error) {
+	args := m.Called(ctx, filter, limit, offset)
+	return args.Get(0).([]domain.CreditFacility), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) FacilitiesToReview(ctx context.Context, limit int) ([]domain.CreditFacility, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]domain.CreditFacility), args.Error(1)
+}
+
+func (m *MockRepository) UpdateFacility(ctx context.Context, f *domain.CreditFacility) error {
+	return m.Called(ctx, f).Error(0)
+}
+
+func (m *MockRepository) DelinquencyReport(ctx context.Context) ([]domain.CreditDelinquencyLine, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.CreditDelinquencyLine), args.Error(1)
+}
+
+type MockLedger struct {
+	mock.Mock
+}
+
+func (m *MockLedger) AccrueCreditInterest(ctx context.Context, a *domain.CreditInterestAccrual) (bool, error) {
+	args := m.Called(ctx, a)
+	return args.Bool(0), args.Error(1)
+}
+
+// CollectCreditInterest sets the collected amount to the third return
+// value, as the ledger does.
+func (m *MockLedger) CollectCreditInterest(ctx context.Context, c *domain.CreditInterestCollection) (bool, error) {
+	args := m.Called(ctx, c)
+	if args.Bool(0) {
+		c.Amount, c.TransactionID = args.Get(2).(decimal.Decimal), uuid.New()
+	}
+	return args.Bool(0), args.Error(1)
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	u, _ := args.Get(0).(*domain.User)
+	return u, args.Error(1)
+}
+
+type MockWallets struct {
+	mock.Mock
+}
+
+func (m *MockWallets) FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error) {
+	args := m.Called(ctx, userID, currency)
+	w, _ := args.Get(0).(*domain.Wallet)
+	return w, args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
+	return m.Called(ctx, userID, eventType, data).Error(0)
+}
+
+type mocks struct {
+	repo     *MockRepository
+	ledger   *MockLedger
+	users    *MockUsers
+	wallets  *MockWallets
+	notifier *MockNotifier
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.ledger.AssertExpectations(t)
+	m.users.AssertExpectations(t)
+	m.wallets.AssertExpectations(t)
+	m.notifier.AssertExpectations(t)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *mocks) {
+	m := &mocks{new(MockRepository), new(MockLedger), new(MockUsers), new(MockWallets), new(MockNotifier)}
+	cfg := config.CreditConfig{Enabled: true, Interval: time.Hour, MaxRiskScore: 30, MaxLimit: 5000, PastDueDays: 30, DelinquentDays: 60}
+	s := NewService(m.repo, m.ledger, m.users, m.wallets, m.notifier, cfg, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, m
+}
+
+// facility is an active facility of 1000 at 36.5% a year, opened at
+// start, with the wallet at balance.
+func facility(balance int64) domain.CreditFacility {
+	return domain.CreditFacility{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		WalletID:       uuid.New(),
+		Currency:       domain.MWK,
+		Limit:          decimal.NewFromInt(1000),
+		AnnualRate:     decimal.RequireFromString("0.365"),
+		Status:         domain.CreditFacilityActive,
+		Delinquency:    domain.CreditCurrent,
+		AccruedThrough: day(start),
+		OpenedAt:       start,
+		Balance:        decimal.NewFromInt(balance),
+	}
+}
+
+func TestOpen(t *testing.T) {
+	user := &domain.User{ID: uuid.New(), KYCStatus: domain.KYCStatusVerified, RiskScore: decimal.NewFromInt(10)}
+	wallet := &domain.Wallet{ID: uuid.New(), UserID: user.ID, Currency: domain.MWK, Status: domain.WalletStatusActive}
+	req := &OpenRequest{UserID: user.ID, Currency: domain.MWK, Limit: decimal.NewFromInt(1000), AnnualRate: decimal.RequireFromString("0.2")}
+	admin := uuid.New()
+
+	now := start
+	s, m := newService(&now)
+	m.users.On("FindByID", ctx, user.ID).Return(user, nil).Once()
+	m.wallets.On("FindByUserAndCurrency", ctx, user.ID, domain.MWK).Return(wallet, nil).Once()
+	m.repo.On("CreateFacility", ctx, mock.MatchedBy(func(f *domain.CreditFacility) bool {
+		return f.WalletID == wallet.ID && f.Status == domain.CreditFacilityActive && f.AccruedThrough.Equal(day(start))
+	})).Return(nil).Once()
+	m.notifier.On("Notify", ctx, user.ID, EventFacilityOpened, map[string]interface{}{
+		"limit": "1000", "currency": domain.MWK, "annual_rate": "20",
+	}).Return(nil).Once()
+
+	f, err := s.Open(ctx, req, &admin)
+	require.NoError(t, err)
+	assert.Equal(t, &admin, f.OpenedBy)
+	assert.True(t, f.AvailableCredit().Equal(decimal.NewFromInt(1000)))
+	m.assert(t)
+
+	t.Run("existing facility", func(t *testing.T) {
+		s, m := newService(&now)
+		m.users.On("FindByID", ctx, user.ID).Return(user, nil).Once()
+		m.wallets.On("FindByUserAndCurrency", ctx, user.ID, domain.MWK).Return(wallet, nil).Once()
+		m.repo.On("CreateFacility", ctx, mock.Anything).Return(ErrFacilityExists).Once()
+
+		_, err := s.Open(ctx, req, nil)
+		assert.ErrorIs(t, err, ErrFacilityExists)
+		m.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestOpenChecksEligibility(t *testing.T) {
+	userID := uuid.New()
+	wallet := &domain.Wallet{ID: uuid.New(), UserID: userID, Currency: domain.MWK, Status: domain.WalletStatusActive}
+	verified := &domain.User{ID: userID, KYCStatus: domain.KYCStatusVerified, RiskScore: decimal.NewFromInt(10)}
+	rate := decimal.RequireFromString("0.2")
+
+	tests := []struct {
+		name    string
+		req     OpenRequest
+		user    *domain.User
+		wallet  *domain.Wallet
+		wantErr error
+	}{
+		{"limit above maximum", OpenRequest{Limit: decimal.NewFromInt(9000), AnnualRate: rate}, nil, nil, ErrInvalidFacility},
+		{"rate above one", OpenRequest{Limit: decimal.NewFromInt(100), AnnualRate: decimal.NewFromInt(2)}, nil, nil, ErrInvalidFacility},
+		{"unknown customer", OpenRequest{Limit: decimal.NewFromInt(100), AnnualRate: rate}, nil, nil, ErrInvalidFacility},
+		{"identity not verified", OpenRequest{Limit: decimal.NewFromInt(100), AnnualRate: rate},
+			&domain.User{ID: userID, KYCStatus: domain.KYCStatusPending}, nil, ErrNotEligible},
+		{"risk too high", OpenRequest{Limit: decimal.NewFromInt(100), AnnualRate: rate},
+			&domain.User{ID: userID, KYCStatus: domain.KYCStatusVerified, RiskScore: decimal.NewFromInt(45)}, nil, ErrNotEligible},
+		{"no wallet", OpenRequest{Limit: decimal.NewFromInt(100), AnnualRate: rate}, verified, nil, ErrInvalidFacility},
+		{"wallet suspended", OpenRequest{Limit: decimal.NewFromInt(100), AnnualRate: rate}, verified,
+			&domain.Wallet{ID: wallet.ID, Status: domain.WalletStatusSuspended}, ErrNotEligible},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			s, m := newService(&now)
+			m.users.On("FindByID", ctx, userID).Return(tt.user, nil).Maybe()
+			m.wallets.On("FindByUserAndCurrency", ctx, userID, domain.MWK).Return(tt.wallet, nil).Maybe()
+			tt.req.UserID, tt.req.Currency = userID, domain.MWK
+
+			_, err := s.Open(ctx, &tt.req, nil)
+			assert.ErrorIs(t, err, tt.wantErr)
+			m.repo.AssertNotCalled(t, "CreateFacility", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	f := facility(0)
+	now := start.Add(time.Hour)
+	s, m := newService(&now)
+	limit := decimal.RequireFromString("1500.004")
+	suspended := domain.CreditFacilitySuspended
+	m.repo.On("GetFacility", ctx, f.ID).Return(&f, nil).Once()
+	m.repo.On("UpdateFacility", ctx, mock.MatchedBy(func(got *domain.CreditFacility) bool {
+		return got.Limit.String() == "1500" && got.Status == suspended && got.UpdatedAt.Equal(now)
+	})).Return(nil).Once()
+
+	_, err := s.Update(ctx, f.ID, &UpdateRequest{Limit: &limit, Status: &suspended})
+	require.NoError(t, err)
+	m.assert(t)
+
+	t.Run("closed facilities cannot change", func(t *testing.T) {
+		closed := f
+		closed.Status = domain.CreditFacilityClosed
+		s, m := newService(&now)
+		m.repo.On("GetFacility", ctx, f.ID).Return(&closed, nil).Once()
+		active := domain.CreditFacilityActive
+
+		_, err := s.Update(ctx, f.ID, &UpdateRequest{Status: &active})
+		assert.ErrorIs(t, err, ErrFacilityClosed)
+		m.repo.AssertNotCalled(t, "UpdateFacility", mock.Anything, mock.Anything)
+	})
+
+	t.Run("closing records when", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetFacility", ctx, f.ID).Return(&f, nil).Once()
+		m.repo.On("UpdateFacility", ctx, mock.MatchedBy(func(got *domain.CreditFacility) bool {
+			return got.Status == domain.CreditFacilityClosed && got.ClosedAt.Equal(now)
+		})).Return(nil).Once()
+		closed := domain.CreditFacilityClosed
+
+		_, err := s.Update(ctx, f.ID, &UpdateRequest{Status: &closed})
+		require.NoError(t, err)
+		m.assert(t)
+	})
+}
+
+func TestRunAccruesInterest(t *testing.T) {
+	f := facility(-1000)
+	// Ten days overdrawn by 1000 at 36.5% a year is 10.00
+	now := start.AddDate(0, 0, 10)
+	s, m := newService(&now)
+	m.repo.On("FacilitiesToReview", ctx, runLimit).Return([]domain.CreditFacility{f}, nil).Once()
+	m.ledger.On("AccrueCreditInterest", ctx, mock.MatchedBy(func(a *domain.CreditInterestAccrual) bool {
+		return a.FacilityID == f.ID && a.Amount.String() == "10" && a.Overdrawn.String() == "1000" &&
+			a.PeriodStart.Equal(day(start)) && a.PeriodEnd.Equal(day(now))
+	})).Return(true, nil).Once()
+	m.repo.On("UpdateFacility", ctx, mock.MatchedBy(func(got *domain.CreditFacility) bool {
+		return got.AccruedThrough.Equal(day(now)) && got.AccruedInterest.String() == "10" && got.OverdrawnSince.Equal(now)
+	})).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CreditRun{Examined: 1, Accrued: 1}, *run)
+	m.assert(t)
+
+	t.Run("already accrued today", func(t *testing.T) {
+		accrued := f
+		accrued.AccruedThrough = day(now)
+		s, m := newService(&now)
+		m.repo.On("FacilitiesToReview", ctx, runLimit).Return([]domain.CreditFacility{accrued}, nil).Once()
+		m.repo.On("UpdateFacility", ctx, mock.Anything).Return(nil).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, run.Accrued)
+		m.ledger.AssertNotCalled(t, "AccrueCreditInterest", mock.Anything, mock.Anything)
+	})
+
+	t.Run("booked by an earlier run", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("FacilitiesToReview", ctx, runLimit).Return([]domain.CreditFacility{f}, nil).Once()
+		m.ledger.On("AccrueCreditInterest", ctx, mock.Anything).Return(false, nil).Once()
+		m.repo.On("UpdateFacility", ctx, mock.MatchedBy(func(got *domain.CreditFacility) bool {
+			return got.AccruedInterest.IsZero()
+		})).Return(nil).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, run.Accrued)
+		m.assert(t)
+	})
+}
+
+func TestRunCollectsInterest(t *testing.T) {
+	// A deposit repaid the overdraft; the interest is swept from it
+	f := facility(50)
+	f.AccruedInterest = decimal.NewFromInt(10)
+	overdrawn := start
+	f.OverdrawnSince = &overdrawn
+	now := start
+	s, m := newService(&now)
+	m.repo.On("FacilitiesToReview", ctx, runLimit).Return([]domain.CreditFacility{f}, nil).Once()
+	m.ledger.On("CollectCreditInterest", ctx, mock.MatchedBy(func(c *domain.CreditInterestCollection) bool {
+		return c.FacilityID == f.ID
+	})).Return(true, nil, decimal.NewFromInt(10)).Once()
+	m.repo.On("UpdateFacility", ctx, mock.MatchedBy(func(got *domain.CreditFacility) bool {
+		return got.AccruedInterest.IsZero() && got.InterestCollected.String() == "10" &&
+			got.Balance.String() == "40" && got.OverdrawnSince == nil && got.Delinquency == domain.CreditCurrent
+	})).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CreditRun{Examined: 1, Collected: 1}, *run)
+	m.assert(t)
+}
+
+func TestRunMarksDelinquency(t *testing.T) {
+	tests := []struct {
+		name     string
+		days     int
+		previous domain.CreditDelinquency
+		want     domain.CreditDelinquency
+		status   domain.CreditFacilityStatus
+		event    string
+	}{
+		{"current", 29, domain.CreditCurrent, domain.CreditCurrent, domain.CreditFacilityActive, ""},
+		{"past due", 30, domain.CreditCurrent, domain.CreditPastDue, domain.CreditFacilityActive, EventPastDue},
+		{"still past due", 45, domain.CreditPastDue, domain.CreditPastDue, domain.CreditFacilityActive, ""},
+		{"delinquent and suspended", 60, domain.CreditPastDue, domain.CreditDelinquent, domain.CreditFacilitySuspended, EventDelinquent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := facility(-200)
+			f.AnnualRate = decimal.Zero
+			f.Delinquency = tt.previous
+			overdrawn := start
+			f.OverdrawnSince = &overdrawn
+			now := start.AddDate(0, 0, tt.days)
+			s, m := newService(&now)
+			m.repo.On("FacilitiesToReview", ctx, runLimit).Return([]domain.CreditFacility{f}, nil).Once()
+			m.repo.On("UpdateFacility", ctx, mock.MatchedBy(func(got *domain.CreditFacility) bool {
+				return got.Delinquency == tt.want && got.Status == tt.status
+			})).Return(nil).Once()
+			if tt.event != "" {
+				m.notifier.On("Notify", ctx, f.UserID, tt.event, map[string]interface{}{
+					"overdrawn": "200", "accrued_interest": "0", "currency": domain.MWK,
+				}).Return(nil).Once()
+			}
+
+			_, err := s.Run(ctx)
+			require.NoError(t, err)
+			m.assert(t)
+		})
+	}
+}
+
+func TestRunCountsFailures(t *testing.T) {
+	failing, ok := facility(-100), facility(0)
+	now := start.AddDate(0, 0, 1)
+	s, m := newService(&now)
+	m.repo.On("FacilitiesToReview", ctx, runLimit).Return([]domain.CreditFacility{failing, ok}, nil).Once()
+	m.ledger.On("AccrueCreditInterest", ctx, mock.Anything).Return(false, errors.New("ledger down")).Once()
+	m.repo.On("UpdateFacility", ctx, mock.MatchedBy(func(got *domain.CreditFacility) bool { return got.ID == ok.ID })).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CreditRun{Examined: 2, Failed: 1}, *run)
+	m.assert(t)
+}
+
+func TestRunRejectsConcurrentRun(t *testing.T) {
+	now := start
+	s, _ := newService(&now)
+	s.running.Lock()
+	defer s.running.Unlock()
+	_, err := s.Run(ctx)
+	assert.ErrorIs(t, err, ErrRunInProgress)
+}
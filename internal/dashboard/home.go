@@ -0,0 +1,196 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	"kyd/internal/wallet"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Customer home section names.
+const (
+	SectionWallets            = "wallets"
+	SectionRecentTransactions = "recent_transactions"
+	SectionPendingPayments    = "pending_payments"
+	SectionKYC                = "kyc"
+	SectionRates              = "rates"
+)
+
+// homeItems is how many recent and pending transactions the home screen
+// lists; the full history is paged from /payments.
+const homeItems = 5
+
+type WalletSource interface {
+	GetUserWallets(ctx context.Context, userID uuid.UUID) ([]*wallet.BalanceResponse, error)
+}
+
+type TransactionSource interface {
+	GetUserTransactions(ctx context.Context, userID uuid.UUID, walletID *uuid.UUID, limit, offset int) ([]*payment.TransactionDetail, int, error)
+	GetUserPendingTransactions(ctx context.Context, userID uuid.UUID, limit int) ([]*payment.TransactionDetail, error)
+}
+
+type UserSource interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+type RateSource interface {
+	GetRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error)
+}
+
+// Corridor is a currency pair whose rate the home screen shows.
+type Corridor struct {
+	From domain.Currency
+	To   domain.Currency
+}
+
+// ParseCorridors reads pairs written as "MWK/CNY".
+func ParseCorridors(pairs []string) ([]Corridor, error) {
+	corridors := make([]Corridor, 0, len(pairs))
+	for _, p := range pairs {
+		from, to, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(p)), "/")
+		if !ok || from == "" || to == "" || from == to {
+			return nil, fmt.Errorf("invalid corridor %q, want FROM/TO", p)
+		}
+		corridors = append(corridors, Corridor{From: domain.Currency(from), To: domain.Currency(to)})
+	}
+	return corridors, nil
+}
+
+// KYCPrompt tells the app whether to nudge the user about verification.
+type KYCPrompt struct {
+	Status         domain.KYCStatus `json:"status"`
+	ActionRequired bool             `json:"action_required"`
+	Message        string           `json:"message,omitempty"`
+}
+
+func kycPrompt(status domain.KYCStatus) KYCPrompt {
+	p := KYCPrompt{Status: status}
+	switch status {
+	case domain.KYCStatusVerified:
+	case domain.KYCStatusProcessing:
+		p.Message = "Your documents are being reviewed."
+	case domain.KYCStatusRejected:
+		p.ActionRequired = true
+		p.Message = "Your verification was not approved. Please resubmit your documents."
+	default:
+		p.ActionRequired = true
+		p.Message = "Verify your identity to unlock higher limits."
+	}
+	return p
+}
+
+// Home builds the customer app's home screen: the user's wallets, latest and
+// pending transactions, a KYC prompt and the corridor rates. Sections load
+// concurrently and fail independently like the admin overview, but nothing
+// is cached since balances must be current.
+type Home struct {
+	wallets        WalletSource
+	transactions   TransactionSource
+	users          UserSource
+	rates          RateSource
+	corridors      []Corridor
+	sectionTimeout time.Duration
+	logger         logger.Logger
+	now            func() time.Time
+}
+
+func NewHome(wallets WalletSource, transactions TransactionSource, users UserSource, rates RateSource, log logger.Logger) *Home {
+	return &Home{
+		wallets:        wallets,
+		transactions:   transactions,
+		users:          users,
+		rates:          rates,
+		sectionTimeout: defaultSectionTimeout,
+		logger:         log,
+		now:            time.Now,
+	}
+}
+
+// WithCorridors sets the rates shown.
+func (h *Home) WithCorridors(corridors []Corridor) *Home {
+	h.corridors = corridors
+	return h
+}
+
+func (h *Home) WithSectionTimeout(d time.Duration) *Home {
+	h.sectionTimeout = d
+	return h
+}
+
+// Build loads the home screen of userID.
+func (h *Home) Build(ctx context.Context, userID uuid.UUID) *Overview {
+	sections := []section{
+		{SectionWallets, func(ctx context.Context) (interface{}, error) {
+			wallets, err := h.wallets.GetUserWallets(ctx, userID)
+			if wallets == nil && err == nil {
+				wallets = []*wallet.BalanceResponse{}
+			}
+			return wallets, err
+		}},
+		{SectionRecentTransactions, func(ctx context.Context) (interface{}, error) {
+			txs, _, err := h.transactions.GetUserTransactions(ctx, userID, nil, homeItems, 0)
+			return nonNilDetails(txs), err
+		}},
+		{SectionPendingPayments, func(ctx context.Context) (interface{}, error) {
+			txs, err := h.transactions.GetUserPendingTransactions(ctx, userID, homeItems)
+			return nonNilDetails(txs), err
+		}},
+		{SectionKYC, func(ctx context.Context) (interface{}, error) {
+			u, err := h.users.FindByID(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			return kycPrompt(u.KYCStatus), nil
+		}},
+		{SectionRates, h.corridorRates},
+	}
+
+	results := collect(ctx, h.sectionTimeout, sections)
+	o := &Overview{GeneratedAt: h.now(), Sections: make(map[string]Section, len(sections))}
+	for _, r := range results {
+		if r.err != nil {
+			o.Partial = true
+			h.logger.Warn("Home section failed", map[string]interface{}{
+				"section": r.name,
+				"user_id": userID.String(),
+				"error":   r.err.Error(),
+			})
+			o.Sections[r.name] = Section{Error: "unavailable"}
+			continue
+		}
+		o.Sections[r.name] = Section{Data: r.data}
+	}
+	return o
+}
+
+// corridorRates returns the rates that loaded, failing only when none did.
+func (h *Home) corridorRates(ctx context.Context) (interface{}, error) {
+	rates := make([]*domain.ExchangeRate, 0, len(h.corridors))
+	var lastErr error
+	for _, c := range h.corridors {
+		rate, err := h.rates.GetRate(ctx, c.From, c.To)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return rates, nil
+}
+
+func nonNilDetails(txs []*payment.TransactionDetail) []*payment.TransactionDetail {
+	if txs == nil {
+		return []*payment.TransactionDetail{}
+	}
+	return txs
+}
@@ -0,0 +1,99 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	"kyd/internal/wallet"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWallets struct{ err error }
+
+func (f fakeWallets) GetUserWallets(ctx context.Context, userID uuid.UUID) ([]*wallet.BalanceResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []*wallet.BalanceResponse{{Currency: domain.MWK, AvailableBalance: decimal.NewFromInt(100)}}, nil
+}
+
+type fakeTransactions struct{ pendingLimit int }
+
+func (f *fakeTransactions) GetUserTransactions(ctx context.Context, userID uuid.UUID, walletID *uuid.UUID, limit, offset int) ([]*payment.TransactionDetail, int, error) {
+	return nil, 0, nil
+}
+
+func (f *fakeTransactions) GetUserPendingTransactions(ctx context.Context, userID uuid.UUID, limit int) ([]*payment.TransactionDetail, error) {
+	f.pendingLimit = limit
+	return []*payment.TransactionDetail{{Transaction: &domain.Transaction{Status: domain.TransactionStatusPendingApproval}}}, nil
+}
+
+type fakeUsers struct{ status domain.KYCStatus }
+
+func (f fakeUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	return &domain.User{ID: id, KYCStatus: f.status}, nil
+}
+
+type fakeRates map[Corridor]error
+
+func (f fakeRates) GetRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
+	if err := f[Corridor{From: from, To: to}]; err != nil {
+		return nil, err
+	}
+	return &domain.ExchangeRate{BaseCurrency: from, TargetCurrency: to, Rate: decimal.NewFromInt(2)}, nil
+}
+
+func TestHomeBuild(t *testing.T) {
+	txs := &fakeTransactions{}
+	mwkCny := Corridor{From: domain.MWK, To: domain.CNY}
+	cnyMwk := Corridor{From: domain.CNY, To: domain.MWK}
+	h := NewHome(fakeWallets{}, txs, fakeUsers{status: domain.KYCStatusRejected},
+		fakeRates{cnyMwk: errors.New("provider down")}, logger.NewNop()).
+		WithCorridors([]Corridor{mwkCny, cnyMwk})
+
+	o := h.Build(context.Background(), uuid.New())
+
+	assert.False(t, o.Partial)
+	assert.Len(t, o.Sections[SectionWallets].Data, 1)
+	assert.Equal(t, []*payment.TransactionDetail{}, o.Sections[SectionRecentTransactions].Data)
+	assert.Len(t, o.Sections[SectionPendingPayments].Data, 1)
+	assert.Equal(t, homeItems, txs.pendingLimit)
+
+	kyc := o.Sections[SectionKYC].Data.(KYCPrompt)
+	assert.True(t, kyc.ActionRequired)
+	assert.Equal(t, domain.KYCStatusRejected, kyc.Status)
+
+	// One corridor failing still serves the other.
+	rates := o.Sections[SectionRates].Data.([]*domain.ExchangeRate)
+	require.Len(t, rates, 1)
+	assert.Equal(t, domain.MWK, rates[0].BaseCurrency)
+}
+
+func TestHomeBuildPartial(t *testing.T) {
+	h := NewHome(fakeWallets{err: errors.New("db down")}, &fakeTransactions{}, fakeUsers{status: domain.KYCStatusVerified}, fakeRates{}, logger.NewNop())
+
+	o := h.Build(context.Background(), uuid.New())
+
+	assert.True(t, o.Partial)
+	assert.Equal(t, "unavailable", o.Sections[SectionWallets].Error)
+	assert.False(t, o.Sections[SectionKYC].Data.(KYCPrompt).ActionRequired)
+}
+
+func TestParseCorridors(t *testing.T) {
+	c, err := ParseCorridors([]string{"mwk/cny", " CNY/MWK "})
+	require.NoError(t, err)
+	assert.Equal(t, []Corridor{{From: domain.MWK, To: domain.CNY}, {From: domain.CNY, To: domain.MWK}}, c)
+
+	for _, bad := range []string{"MWK", "MWK/", "MWK/MWK"} {
+		_, err := ParseCorridors([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
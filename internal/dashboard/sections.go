@@ -0,0 +1,126 @@
+package dashboard
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	"kyd/internal/settlement"
+)
+
+// Section names, as they appear in Overview.Sections.
+const (
+	SectionSystemStats     = "system_stats"
+	SectionPendingKYC      = "pending_kyc"
+	SectionRiskAlerts      = "risk_alerts"
+	SectionSettlementQueue = "settlement_queue"
+	SectionNetworkHealth   = "network_health"
+)
+
+// recentRiskAlerts is how many flagged transactions the dashboard lists.
+const recentRiskAlerts = 5
+
+type StatsSource interface {
+	GetSystemStats(ctx context.Context) (*domain.SystemStats, error)
+}
+
+// SystemStats is the transaction, volume and user totals section.
+func SystemStats(src StatsSource) Fetcher {
+	return func(ctx context.Context) (interface{}, error) {
+		return src.GetSystemStats(ctx)
+	}
+}
+
+type KYCSource interface {
+	CountApplications(ctx context.Context, status string) (int, error)
+}
+
+type PendingKYCCounts struct {
+	Pending    int `json:"pending"`
+	Processing int `json:"processing"`
+}
+
+// PendingKYC counts the KYC applications waiting for review.
+func PendingKYC(src KYCSource) Fetcher {
+	return func(ctx context.Context) (interface{}, error) {
+		var c PendingKYCCounts
+		var err error
+		if c.Pending, err = src.CountApplications(ctx, string(domain.KYCStatusPending)); err != nil {
+			return nil, err
+		}
+		if c.Processing, err = src.CountApplications(ctx, string(domain.KYCStatusProcessing)); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+type RiskSource interface {
+	GetRiskAlerts(ctx context.Context, limit, offset int) ([]*payment.TransactionDetail, int, error)
+}
+
+type RiskAlertSummary struct {
+	Total  int                          `json:"total"`
+	Recent []*payment.TransactionDetail `json:"recent"`
+}
+
+// RiskAlerts is the flagged transaction count with the latest few.
+func RiskAlerts(src RiskSource) Fetcher {
+	return func(ctx context.Context) (interface{}, error) {
+		recent, total, err := src.GetRiskAlerts(ctx, recentRiskAlerts, 0)
+		if err != nil {
+			return nil, err
+		}
+		if recent == nil {
+			recent = []*payment.TransactionDetail{}
+		}
+		return RiskAlertSummary{Total: total, Recent: recent}, nil
+	}
+}
+
+type SettlementSource interface {
+	CountByStatus(ctx context.Context, status domain.SettlementStatus) (int, error)
+}
+
+// SettlementQueueDepth counts settlements by the stages before completion,
+// plus failures awaiting a retry.
+type SettlementQueueDepth struct {
+	Pending    int `json:"pending"`
+	Processing int `json:"processing"`
+	Submitted  int `json:"submitted"`
+	Failed     int `json:"failed"`
+}
+
+// SettlementQueue is the settlement backlog section.
+func SettlementQueue(src SettlementSource) Fetcher {
+	return func(ctx context.Context) (interface{}, error) {
+		var q SettlementQueueDepth
+		for _, c := range []struct {
+			status domain.SettlementStatus
+			into   *int
+		}{
+			{domain.SettlementStatusPending, &q.Pending},
+			{domain.SettlementStatusProcessing, &q.Processing},
+			{domain.SettlementStatusSubmitted, &q.Submitted},
+			{domain.SettlementStatusFailed, &q.Failed},
+		} {
+			n, err := src.CountByStatus(ctx, c.status)
+			if err != nil {
+				return nil, err
+			}
+			*c.into = n
+		}
+		return q, nil
+	}
+}
+
+type NetworkSource interface {
+	ThrottleStatus(ctx context.Context) []settlement.NetworkThrottle
+}
+
+// NetworkHealth is each blockchain network's health and submission state.
+func NetworkHealth(src NetworkSource) Fetcher {
+	return func(ctx context.Context) (interface{}, error) {
+		return src.ThrottleStatus(ctx), nil
+	}
+}
@@ -0,0 +1,172 @@
+// Package dashboard aggregates what the admin dashboard and the customer
+// app's home screen show into one response each, so the frontends render
+// them with a single call instead of one per widget.
+package dashboard
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"kyd/pkg/logger"
+)
+
+const (
+	defaultCacheTTL       = 30 * time.Second
+	defaultSectionTimeout = 3 * time.Second
+)
+
+// Fetcher loads the data of one dashboard section.
+type Fetcher func(ctx context.Context) (interface{}, error)
+
+// Section is one widget's data. When its fetch fails, Error says so and Data
+// holds the last value that loaded, marked Stale, if there is one.
+type Section struct {
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Stale     bool        `json:"stale,omitempty"`
+	UpdatedAt *time.Time  `json:"updated_at,omitempty"`
+}
+
+// Overview is the aggregated dashboard. Partial is set when any section
+// failed; the others are still served.
+type Overview struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Partial     bool               `json:"partial"`
+	Sections    map[string]Section `json:"sections"`
+}
+
+type section struct {
+	name  string
+	fetch Fetcher
+}
+
+// Service builds the overview, fetching sections concurrently, each under
+// its own timeout so one slow source cannot hold up the rest, and caches it
+// for a short TTL so a room of admins refreshing does not multiply the load.
+type Service struct {
+	sections       []section
+	cacheTTL       time.Duration
+	sectionTimeout time.Duration
+	logger         logger.Logger
+	now            func() time.Time
+
+	// refresh serialises rebuilds so concurrent misses share one.
+	refresh  sync.Mutex
+	mu       sync.Mutex
+	cached   *Overview
+	lastGood map[string]Section
+}
+
+func NewService(log logger.Logger) *Service {
+	return &Service{
+		cacheTTL:       defaultCacheTTL,
+		sectionTimeout: defaultSectionTimeout,
+		logger:         log,
+		now:            time.Now,
+		lastGood:       make(map[string]Section),
+	}
+}
+
+// WithSection adds a section under name.
+func (s *Service) WithSection(name string, fetch Fetcher) *Service {
+	s.sections = append(s.sections, section{name: name, fetch: fetch})
+	return s
+}
+
+func (s *Service) WithCacheTTL(ttl time.Duration) *Service {
+	s.cacheTTL = ttl
+	return s
+}
+
+func (s *Service) WithSectionTimeout(d time.Duration) *Service {
+	s.sectionTimeout = d
+	return s
+}
+
+// Overview returns the cached overview while it is fresh and rebuilds it
+// otherwise. fresh forces a rebuild.
+func (s *Service) Overview(ctx context.Context, fresh bool) *Overview {
+	if !fresh {
+		if o := s.cachedOverview(); o != nil {
+			return o
+		}
+	}
+
+	s.refresh.Lock()
+	defer s.refresh.Unlock()
+	// Another caller may have rebuilt it while we waited.
+	if !fresh {
+		if o := s.cachedOverview(); o != nil {
+			return o
+		}
+	}
+
+	o := s.build(ctx)
+	s.mu.Lock()
+	s.cached = o
+	s.mu.Unlock()
+	return o
+}
+
+func (s *Service) cachedOverview() *Overview {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && s.now().Sub(s.cached.GeneratedAt) < s.cacheTTL {
+		return s.cached
+	}
+	return nil
+}
+
+func (s *Service) build(ctx context.Context) *Overview {
+	results := collect(ctx, s.sectionTimeout, s.sections)
+
+	now := s.now()
+	o := &Overview{GeneratedAt: now, Sections: make(map[string]Section, len(s.sections))}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range results {
+		if r.err == nil {
+			updated := now
+			sec := Section{Data: r.data, UpdatedAt: &updated}
+			s.lastGood[r.name] = sec
+			o.Sections[r.name] = sec
+			continue
+		}
+		o.Partial = true
+		s.logger.Warn("Dashboard section failed", map[string]interface{}{
+			"section": r.name,
+			"error":   r.err.Error(),
+		})
+		sec := Section{Error: "unavailable"}
+		if last, ok := s.lastGood[r.name]; ok {
+			sec.Data, sec.UpdatedAt, sec.Stale = last.Data, last.UpdatedAt, true
+		}
+		o.Sections[r.name] = sec
+	}
+	return o
+}
+
+type result struct {
+	name string
+	data interface{}
+	err  error
+}
+
+// collect runs every section's fetch concurrently, each bounded by timeout.
+func collect(ctx context.Context, timeout time.Duration, sections []section) []result {
+	results := make([]result, len(sections))
+	var wg sync.WaitGroup
+	for i, sec := range sections {
+		wg.Add(1)
+		go func(i int, sec section) {
+			defer wg.Done()
+			sctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			data, err := sec.fetch(sctx)
+			results[i] = result{name: sec.name, data: data, err: err}
+		}(i, sec)
+	}
+	wg.Wait()
+	return results
+}
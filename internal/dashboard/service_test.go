@@ -0,0 +1,112 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestService(c *clock) *Service {
+	s := NewService(logger.NewNop())
+	s.now = c.now
+	return s
+}
+
+func TestOverviewToleratesFailingSection(t *testing.T) {
+	c := &clock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	var fail atomic.Bool
+	s := newTestService(c).
+		WithSection("ok", func(ctx context.Context) (interface{}, error) { return 1, nil }).
+		WithSection("flaky", func(ctx context.Context) (interface{}, error) {
+			if fail.Load() {
+				return nil, errors.New("db down")
+			}
+			return "fine", nil
+		})
+
+	o := s.Overview(context.Background(), false)
+	assert.False(t, o.Partial)
+	assert.Equal(t, "fine", o.Sections["flaky"].Data)
+
+	// A failure serves the last good value, marked stale.
+	fail.Store(true)
+	o = s.Overview(context.Background(), true)
+	assert.True(t, o.Partial)
+	assert.Equal(t, 1, o.Sections["ok"].Data)
+	flaky := o.Sections["flaky"]
+	assert.Equal(t, "unavailable", flaky.Error)
+	assert.True(t, flaky.Stale)
+	assert.Equal(t, "fine", flaky.Data)
+}
+
+func TestOverviewFailingSectionWithoutHistory(t *testing.T) {
+	s := newTestService(&clock{t: time.Now()}).
+		WithSection("broken", func(ctx context.Context) (interface{}, error) { return nil, errors.New("boom") })
+
+	o := s.Overview(context.Background(), false)
+	require.True(t, o.Partial)
+	assert.Nil(t, o.Sections["broken"].Data)
+	assert.False(t, o.Sections["broken"].Stale)
+}
+
+func TestOverviewCachesForTTL(t *testing.T) {
+	c := &clock{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	var calls atomic.Int32
+	s := newTestService(c).WithCacheTTL(time.Minute).
+		WithSection("count", func(ctx context.Context) (interface{}, error) { return calls.Add(1), nil })
+
+	s.Overview(context.Background(), false)
+	c.t = c.t.Add(30 * time.Second)
+	s.Overview(context.Background(), false)
+	assert.Equal(t, int32(1), calls.Load())
+
+	s.Overview(context.Background(), true)
+	assert.Equal(t, int32(2), calls.Load())
+
+	c.t = c.t.Add(2 * time.Minute)
+	s.Overview(context.Background(), false)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestOverviewBoundsSlowSections(t *testing.T) {
+	s := newTestService(&clock{t: time.Now()}).WithSectionTimeout(20*time.Millisecond).
+		WithSection("slow", func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).
+		WithSection("fast", func(ctx context.Context) (interface{}, error) { return "ok", nil })
+
+	start := time.Now()
+	o := s.Overview(context.Background(), false)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, o.Partial)
+	assert.Equal(t, "ok", o.Sections["fast"].Data)
+}
+
+type fakeSettlements map[domain.SettlementStatus]int
+
+func (f fakeSettlements) CountByStatus(ctx context.Context, status domain.SettlementStatus) (int, error) {
+	return f[status], nil
+}
+
+func TestSettlementQueue(t *testing.T) {
+	data, err := SettlementQueue(fakeSettlements{
+		domain.SettlementStatusPending:   4,
+		domain.SettlementStatusSubmitted: 2,
+		domain.SettlementStatusFailed:    1,
+	})(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SettlementQueueDepth{Pending: 4, Submitted: 2, Failed: 1}, data)
+}
@@ -0,0 +1,452 @@
+// Package datasharing lets users share a standardized summary of their
+// transactions with credit-scoring partners to access loans.
+//
+// A user grants a partner consent to some scopes of the summary (income,
+// volume, regularity) for a number of days. The partner reads the summary
+// with its key, which reaches nothing but the consents granted to it and
+// only their scopes. Every read is logged for the user to see, and the
+// user may revoke a consent at any time.
+package datasharing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrPartnerNotFound   = errors.New("data partner not found")
+	ErrInvalidPartner    = errors.New("invalid data partner")
+	ErrInvalidPartnerKey = errors.New("invalid partner key")
+	ErrConsentNotFound   = errors.New("consent not found")
+	ErrConsentInactive   = errors.New("consent has expired or been revoked")
+	ErrInvalidConsent    = errors.New("invalid consent")
+)
+
+const (
+	partnerKeyPrefix = "kyd_partner_"
+
+	DefaultLookbackMonths = 6
+	MaxLookbackMonths     = 24
+	DefaultConsentDays    = 90
+	MaxConsentDays        = 365
+)
+
+// Repository persists partners, consents and the access log, and sums the
+// transactions summaries are built from.
+type Repository interface {
+	CreatePartner(ctx context.Context, p *domain.DataPartner) error
+	GetPartner(ctx context.Context, id uuid.UUID) (*domain.DataPartner, error)
+	// PartnerByKeyHash returns nil when no partner has the key.
+	PartnerByKeyHash(ctx context.Context, hash string) (*domain.DataPartner, error)
+	ListPartners(ctx context.Context, activeOnly bool) ([]domain.DataPartner, error)
+	SetPartnerActive(ctx context.Context, id uuid.UUID, active bool, at time.Time) (bool, error)
+
+	CreateConsent(ctx context.Context, c *domain.DataSharingConsent) error
+	GetConsent(ctx context.Context, id uuid.UUID) (*domain.DataSharingConsent, error)
+	ListConsents(ctx context.Context, userID uuid.UUID) ([]domain.DataSharingConsent, error)
+	// ListPartnerConsents returns the partner's consents in force at now,
+	// those with reference only when it is not empty.
+	ListPartnerConsents(ctx context.Context, partnerID uuid.UUID, reference string, now time.Time) ([]domain.DataSharingConsent, error)
+	// RevokeConsent reports false when the user has no such consent in force.
+	RevokeConsent(ctx context.Context, userID, id uuid.UUID, at time.Time) (bool, error)
+
+	LogAccess(ctx context.Context, a *domain.DataSharingAccess) error
+	// ListAccess returns the user's access log, newest first, for one
+	// consent when consentID is set.
+	ListAccess(ctx context.Context, userID uuid.UUID, consentID *uuid.UUID, limit, offset int) ([]domain.DataSharingAccess, int, error)
+
+	// MonthlyFlows sums the user's completed transactions in [from, to) by
+	// month and currency.
+	MonthlyFlows(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]domain.MonthlyFlow, error)
+}
+
+type Service struct {
+	repo   Repository
+	logger logger.Logger
+	now    func() time.Time
+}
+
+func NewService(repo Repository, log logger.Logger) *Service {
+	return &Service{repo: repo, logger: log, now: time.Now}
+}
+
+// CreatePartner registers a partner and returns its key, which is not
+// stored and cannot be shown again.
+func (s *Service) CreatePartner(ctx context.Context, name, description string, createdBy *uuid.UUID) (*domain.DataPartner, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, "", fmt.Errorf("%w: name is required and at most 100 characters", ErrInvalidPartner)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	rawKey := partnerKeyPrefix + hex.EncodeToString(b)
+	now := s.now()
+	p := &domain.DataPartner{
+		ID:          uuid.New(),
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		KeyPrefix:   rawKey[:len(partnerKeyPrefix)+4],
+		KeyHash:     hashKey(rawKey),
+		Active:      true,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.CreatePartner(ctx, p); err != nil {
+		return nil, "", err
+	}
+	return p, rawKey, nil
+}
+
+// Partners lists partners; users only see the active ones.
+func (s *Service) Partners(ctx context.Context, activeOnly bool) ([]domain.DataPartner, error) {
+	return s.repo.ListPartners(ctx, activeOnly)
+}
+
+// SetPartnerActive suspends or reinstates a partner. A suspended partner's
+// key is refused and users cannot grant it consent.
+func (s *Service) SetPartnerActive(ctx context.Context, id uuid.UUID, active bool) (*domain.DataPartner, error) {
+	ok, err := s.repo.SetPartnerActive(ctx, id, active, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPartnerNotFound
+	}
+	return s.repo.GetPartner(ctx, id)
+}
+
+// GrantRequest is a user's consent to share with a partner. Zero
+// LookbackMonths and ExpiresInDays take the defaults.
+type GrantRequest struct {
+	PartnerID        uuid.UUID `json:"partner_id" validate:"required"`
+	Scopes           []string  `json:"scopes" validate:"required,min=1"`
+	LookbackMonths   int       `json:"lookback_months"`
+	ExpiresInDays    int       `json:"expires_in_days"`
+	PartnerReference string    `json:"partner_reference" validate:"max=100"`
+}
+
+// Grant records the user's consent to share the requested scopes with an
+// active partner.
+func (s *Service) Grant(ctx context.Context, userID uuid.UUID, req *GrantRequest) (*domain.DataSharingConsent, error) {
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	lookback := req.LookbackMonths
+	if lookback == 0 {
+		lookback = DefaultLookbackMonths
+	}
+	if lookback < 1 || lookback > MaxLookbackMonths {
+		return nil, fmt.Errorf("%w: lookback_months must be between 1 and %d", ErrInvalidConsent, MaxLookbackMonths)
+	}
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = DefaultConsentDays
+	}
+	if days < 1 || days > MaxConsentDays {
+		return nil, fmt.Errorf("%w: expires_in_days must be between 1 and %d", ErrInvalidConsent, MaxConsentDays)
+	}
+	partner, err := s.repo.GetPartner(ctx, req.PartnerID)
+	if errors.Is(err, ErrPartnerNotFound) || (err == nil && !partner.Active) {
+		return nil, fmt.Errorf("%w: unknown partner", ErrInvalidConsent)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	c := &domain.DataSharingConsent{
+		ID:               uuid.New(),
+		UserID:           userID,
+		PartnerID:        partner.ID,
+		PartnerName:      partner.Name,
+		Scopes:           scopes,
+		LookbackMonths:   lookback,
+		PartnerReference: strings.TrimSpace(req.PartnerReference),
+		GrantedAt:        now,
+		ExpiresAt:        now.AddDate(0, 0, days),
+	}
+	if err := s.repo.CreateConsent(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Consents lists the user's consents, newest first.
+func (s *Service) Consents(ctx context.Context, userID uuid.UUID) ([]domain.DataSharingConsent, error) {
+	return s.repo.ListConsents(ctx, userID)
+}
+
+// Revoke withdraws one of the user's consents at once.
+func (s *Service) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	ok, err := s.repo.RevokeConsent(ctx, userID, id, s.now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConsentNotFound
+	}
+	return nil
+}
+
+// AccessLog lists partners' reads of the user's data, for one consent when
+// consentID is set.
+func (s *Service) AccessLog(ctx context.Context, userID uuid.UUID, consentID *uuid.UUID, limit, offset int) ([]domain.DataSharingAccess, int, error) {
+	if consentID != nil {
+		c, err := s.repo.GetConsent(ctx, *consentID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if c.UserID != userID {
+			return nil, 0, ErrConsentNotFound
+		}
+	}
+	return s.repo.ListAccess(ctx, userID, consentID, limit, offset)
+}
+
+// Authenticate returns the active partner a key belongs to.
+func (s *Service) Authenticate(ctx context.Context, rawKey string) (*domain.DataPartner, error) {
+	if !strings.HasPrefix(rawKey, partnerKeyPrefix) {
+		return nil, ErrInvalidPartnerKey
+	}
+	p, err := s.repo.PartnerByKeyHash(ctx, hashKey(rawKey))
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || !p.Active {
+		return nil, ErrInvalidPartnerKey
+	}
+	return p, nil
+}
+
+// PartnerConsents lists the consents in force granted to partner, those
+// with reference only when it is not empty.
+func (s *Service) PartnerConsents(ctx context.Context, partner *domain.DataPartner, reference string) ([]domain.DataSharingConsent, error) {
+	consents, err := s.repo.ListPartnerConsents(ctx, partner.ID, strings.TrimSpace(reference), s.now())
+	if err != nil {
+		return nil, err
+	}
+	// The partner has no business knowing which user it is beyond its own
+	// reference.
+	for i := range consents {
+		consents[i].UserID = uuid.Nil
+	}
+	return consents, nil
+}
+
+// Summary builds the summary a consent lets partner read and logs the
+// read. Consents granted to other partners are not found.
+func (s *Service) Summary(ctx context.Context, partner *domain.DataPartner, consentID uuid.UUID, ip string) (*domain.CreditSummary, error) {
+	c, err := s.repo.GetConsent(ctx, consentID)
+	if err != nil {
+		return nil, err
+	}
+	if c.PartnerID != partner.ID {
+		return nil, ErrConsentNotFound
+	}
+	now := s.now().UTC()
+	if c.Status(now) != domain.DataConsentActive {
+		return nil, ErrConsentInactive
+	}
+
+	// Whole months only, so the current month's part does not drag the
+	// figures down.
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, -c.LookbackMonths, 0)
+	flows, err := s.repo.MonthlyFlows(ctx, c.UserID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	summary := Summarize(c, flows, start, end)
+	summary.GeneratedAt = now
+
+	access := &domain.DataSharingAccess{
+		ID:          uuid.New(),
+		ConsentID:   c.ID,
+		UserID:      c.UserID,
+		PartnerID:   partner.ID,
+		PartnerName: partner.Name,
+		Scopes:      c.Scopes,
+		IPAddress:   ip,
+		AccessedAt:  now,
+	}
+	// A read the user cannot see is not allowed to happen
+	if err := s.repo.LogAccess(ctx, access); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Partner read data sharing summary", map[string]interface{}{
+		"event":      "data_sharing_access",
+		"partner_id": partner.ID,
+		"consent_id": c.ID,
+		"scopes":     []string(c.Scopes),
+	})
+	return summary, nil
+}
+
+// Summarize builds the summary of flows over the months in [start, end)
+// that consent c grants.
+func Summarize(c *domain.DataSharingConsent, flows []domain.MonthlyFlow, start, end time.Time) *domain.CreditSummary {
+	months := 0
+	for m := start; m.Before(end); m = m.AddDate(0, 1, 0) {
+		months++
+	}
+	byCurrency := make(map[string][]domain.MonthlyFlow)
+	for _, f := range flows {
+		byCurrency[f.Currency] = append(byCurrency[f.Currency], f)
+	}
+	currencies := make([]string, 0, len(byCurrency))
+	for cur := range byCurrency {
+		currencies = append(currencies, cur)
+	}
+	sort.Strings(currencies)
+
+	summary := &domain.CreditSummary{
+		ConsentID:        c.ID,
+		PartnerReference: c.PartnerReference,
+		Scopes:           []string(c.Scopes),
+		PeriodStart:      start,
+		PeriodEnd:        end,
+		Months:           months,
+		Currencies:       make([]domain.CurrencyCreditSummary, 0, len(currencies)),
+	}
+	n := decimal.NewFromInt(int64(months))
+	for _, cur := range currencies {
+		incomes := make([]decimal.Decimal, months)
+		var (
+			volume                         domain.VolumeSummary
+			totalIncome                    = decimal.Zero
+			monthsWithIncome, activeMonths int
+		)
+		for _, f := range byCurrency[cur] {
+			i := monthIndex(start, f.Month)
+			if i < 0 || i >= months {
+				continue
+			}
+			incomes[i] = incomes[i].Add(f.IncomeAmount)
+			totalIncome = totalIncome.Add(f.IncomeAmount)
+			volume.InflowCount += f.InflowCount
+			volume.InflowAmount = volume.InflowAmount.Add(f.InflowAmount)
+			volume.OutflowCount += f.OutflowCount
+			volume.OutflowAmount = volume.OutflowAmount.Add(f.OutflowAmount)
+			if f.InflowCount+f.OutflowCount > 0 {
+				activeMonths++
+			}
+		}
+		for _, v := range incomes {
+			if v.IsPositive() {
+				monthsWithIncome++
+			}
+		}
+
+		out := domain.CurrencyCreditSummary{Currency: cur}
+		mean := decimal.Zero
+		if months > 0 {
+			mean = totalIncome.Div(n)
+		}
+		if c.Allows(domain.DataScopeIncome) {
+			out.Income = &domain.IncomeSummary{
+				Total:          totalIncome.Round(2),
+				MonthlyAverage: mean.Round(2),
+				MonthlyMedian:  median(incomes).Round(2),
+			}
+		}
+		if c.Allows(domain.DataScopeVolume) {
+			volume.InflowAmount = volume.InflowAmount.Round(2)
+			volume.OutflowAmount = volume.OutflowAmount.Round(2)
+			if months > 0 {
+				volume.MonthlyTransactions = decimal.NewFromInt(int64(volume.InflowCount + volume.OutflowCount)).Div(n).Round(2)
+			}
+			out.Volume = &volume
+		}
+		if c.Allows(domain.DataScopeRegularity) {
+			reg := &domain.RegularitySummary{MonthsWithIncome: monthsWithIncome, ActiveMonths: activeMonths}
+			if months > 0 {
+				reg.IncomeMonthsRatio = decimal.NewFromInt(int64(monthsWithIncome)).Div(n).Round(2)
+			}
+			if mean.IsPositive() {
+				reg.IncomeVariation = stddev(incomes, mean).Div(mean).Round(2)
+			}
+			out.Regularity = reg
+		}
+		summary.Currencies = append(summary.Currencies, out)
+	}
+	return summary
+}
+
+func monthIndex(start, month time.Time) int {
+	month = month.UTC()
+	return (month.Year()-start.Year())*12 + int(month.Month()) - int(start.Month())
+}
+
+func median(values []decimal.Decimal) decimal.Decimal {
+	if len(values) == 0 {
+		return decimal.Zero
+	}
+	sorted := append([]decimal.Decimal(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].LessThan(sorted[j]) })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return sorted[mid-1].Add(sorted[mid]).Div(decimal.NewFromInt(2))
+}
+
+// stddev is the population standard deviation of values about mean.
+func stddev(values []decimal.Decimal, mean decimal.Decimal) decimal.Decimal {
+	sum := decimal.Zero
+	for _, v := range values {
+		d := v.Sub(mean)
+		sum = sum.Add(d.Mul(d))
+	}
+	variance, _ := sum.Div(decimal.NewFromInt(int64(len(values)))).Float64()
+	return decimal.NewFromFloat(math.Sqrt(variance))
+}
+
+func normalizeScopes(requested []string) (pq.StringArray, error) {
+	seen := make(map[domain.DataSharingScope]bool)
+	for _, raw := range requested {
+		scope := domain.DataSharingScope(strings.ToLower(strings.TrimSpace(raw)))
+		known := false
+		for _, s := range domain.DataSharingScopes {
+			known = known || s == scope
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidConsent, raw)
+		}
+		seen[scope] = true
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidConsent)
+	}
+	// Keep the canonical order so equal grants read the same
+	var scopes pq.StringArray
+	for _, s := range domain.DataSharingScopes {
+		if seen[s] {
+			scopes = append(scopes, string(s))
+		}
+	}
+	return scopes, nil
+}
+
+func hashKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
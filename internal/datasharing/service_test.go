@@ -0,0 +1,293 @@
+package datasharing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRepository struct {
+	partners map[uuid.UUID]*domain.DataPartner
+	consents map[uuid.UUID]*domain.DataSharingConsent
+	access   []domain.DataSharingAccess
+	flows    []domain.MonthlyFlow
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		partners: make(map[uuid.UUID]*domain.DataPartner),
+		consents: make(map[uuid.UUID]*domain.DataSharingConsent),
+	}
+}
+
+func (m *memoryRepository) CreatePartner(ctx context.Context, p *domain.DataPartner) error {
+	cp := *p
+	m.partners[p.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepository) GetPartner(ctx context.Context, id uuid.UUID) (*domain.DataPartner, error) {
+	p, ok := m.partners[id]
+	if !ok {
+		return nil, ErrPartnerNotFound
+	}
+	cp := *p
+	return &cp, nil
+}
+
+func (m *memoryRepository) PartnerByKeyHash(ctx context.Context, hash string) (*domain.DataPartner, error) {
+	for _, p := range m.partners {
+		if p.KeyHash == hash {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryRepository) ListPartners(ctx context.Context, activeOnly bool) ([]domain.DataPartner, error) {
+	var out []domain.DataPartner
+	for _, p := range m.partners {
+		if !activeOnly || p.Active {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) SetPartnerActive(ctx context.Context, id uuid.UUID, active bool, at time.Time) (bool, error) {
+	p, ok := m.partners[id]
+	if !ok {
+		return false, nil
+	}
+	p.Active, p.UpdatedAt = active, at
+	return true, nil
+}
+
+func (m *memoryRepository) CreateConsent(ctx context.Context, c *domain.DataSharingConsent) error {
+	cp := *c
+	m.consents[c.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepository) GetConsent(ctx context.Context, id uuid.UUID) (*domain.DataSharingConsent, error) {
+	c, ok := m.consents[id]
+	if !ok {
+		return nil, ErrConsentNotFound
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (m *memoryRepository) ListConsents(ctx context.Context, userID uuid.UUID) ([]domain.DataSharingConsent, error) {
+	var out []domain.DataSharingConsent
+	for _, c := range m.consents {
+		if c.UserID == userID {
+			out = append(out, *c)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) ListPartnerConsents(ctx context.Context, partnerID uuid.UUID, reference string, now time.Time) ([]domain.DataSharingConsent, error) {
+	var out []domain.DataSharingConsent
+	for _, c := range m.consents {
+		if c.PartnerID == partnerID && c.Status(now) == domain.DataConsentActive &&
+			(reference == "" || c.PartnerReference == reference) {
+			out = append(out, *c)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) RevokeConsent(ctx context.Context, userID, id uuid.UUID, at time.Time) (bool, error) {
+	c, ok := m.consents[id]
+	if !ok || c.UserID != userID || c.Status(at) != domain.DataConsentActive {
+		return false, nil
+	}
+	c.RevokedAt = &at
+	return true, nil
+}
+
+func (m *memoryRepository) LogAccess(ctx context.Context, a *domain.DataSharingAccess) error {
+	m.access = append(m.access, *a)
+	return nil
+}
+
+func (m *memoryRepository) ListAccess(ctx context.Context, userID uuid.UUID, consentID *uuid.UUID, limit, offset int) ([]domain.DataSharingAccess, int, error) {
+	var out []domain.DataSharingAccess
+	for _, a := range m.access {
+		if a.UserID == userID && (consentID == nil || a.ConsentID == *consentID) {
+			out = append(out, a)
+		}
+	}
+	return out, len(out), nil
+}
+
+func (m *memoryRepository) MonthlyFlows(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]domain.MonthlyFlow, error) {
+	var out []domain.MonthlyFlow
+	for _, f := range m.flows {
+		if !f.Month.Before(from) && f.Month.Before(to) {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func newTestService(t *testing.T, now time.Time) (*Service, *memoryRepository, *domain.DataPartner, string) {
+	repo := newMemoryRepository()
+	s := NewService(repo, logger.NewNop())
+	s.now = func() time.Time { return now }
+	partner, key, err := s.CreatePartner(context.Background(), " Acme Credit ", "", nil)
+	require.NoError(t, err)
+	return s, repo, partner, key
+}
+
+func flow(month time.Time, currency string, income, inflow, outflow int64) domain.MonthlyFlow {
+	f := domain.MonthlyFlow{Month: month, Currency: currency}
+	if income > 0 {
+		f.IncomeCount, f.IncomeAmount = 1, decimal.NewFromInt(income)
+	}
+	if inflow > 0 {
+		f.InflowCount, f.InflowAmount = 1, decimal.NewFromInt(inflow)
+	}
+	if outflow > 0 {
+		f.OutflowCount, f.OutflowAmount = 1, decimal.NewFromInt(outflow)
+	}
+	return f
+}
+
+func TestSummarizeFillsOnlyGrantedScopes(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 4, 0)
+	flows := []domain.MonthlyFlow{
+		flow(start, "MWK", 100, 150, 80),
+		flow(start.AddDate(0, 1, 0), "MWK", 300, 300, 0),
+		// No income in March
+		flow(start.AddDate(0, 3, 0), "MWK", 200, 200, 50),
+		flow(start.AddDate(0, 2, 0), "CNY", 0, 0, 40),
+	}
+	consent := &domain.DataSharingConsent{ID: uuid.New(), Scopes: []string{"income", "regularity"}}
+
+	summary := Summarize(consent, flows, start, end)
+	assert.Equal(t, 4, summary.Months)
+	require.Len(t, summary.Currencies, 2)
+	assert.Equal(t, "CNY", summary.Currencies[0].Currency)
+
+	mwk := summary.Currencies[1]
+	assert.Nil(t, mwk.Volume, "volume was not granted")
+	require.NotNil(t, mwk.Income)
+	assert.Equal(t, "600", mwk.Income.Total.String())
+	assert.Equal(t, "150", mwk.Income.MonthlyAverage.String())
+	assert.Equal(t, "150", mwk.Income.MonthlyMedian.String())
+	require.NotNil(t, mwk.Regularity)
+	assert.Equal(t, 3, mwk.Regularity.MonthsWithIncome)
+	assert.Equal(t, 3, mwk.Regularity.ActiveMonths)
+	assert.Equal(t, "0.75", mwk.Regularity.IncomeMonthsRatio.String())
+	// Monthly incomes 100, 300, 0, 200 have a standard deviation of 111.8
+	assert.Equal(t, "0.75", mwk.Regularity.IncomeVariation.String())
+
+	consent.Scopes = []string{"volume"}
+	mwk = Summarize(consent, flows, start, end).Currencies[1]
+	assert.Nil(t, mwk.Income)
+	assert.Nil(t, mwk.Regularity)
+	require.NotNil(t, mwk.Volume)
+	assert.Equal(t, 3, mwk.Volume.InflowCount)
+	assert.Equal(t, "650", mwk.Volume.InflowAmount.String())
+	assert.Equal(t, 2, mwk.Volume.OutflowCount)
+	assert.Equal(t, "1.25", mwk.Volume.MonthlyTransactions.String())
+}
+
+func TestGrantValidatesRequest(t *testing.T) {
+	ctx := context.Background()
+	s, _, partner, _ := newTestService(t, time.Now())
+	userID := uuid.New()
+
+	for _, req := range []GrantRequest{
+		{PartnerID: partner.ID, Scopes: []string{"salary"}},
+		{PartnerID: partner.ID, Scopes: []string{"income"}, LookbackMonths: MaxLookbackMonths + 1},
+		{PartnerID: partner.ID, Scopes: []string{"income"}, ExpiresInDays: -1},
+		{PartnerID: uuid.New(), Scopes: []string{"income"}},
+	} {
+		_, err := s.Grant(ctx, userID, &req)
+		assert.ErrorIs(t, err, ErrInvalidConsent)
+	}
+
+	consent, err := s.Grant(ctx, userID, &GrantRequest{PartnerID: partner.ID, Scopes: []string{"Regularity", "income", "income"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"income", "regularity"}, []string(consent.Scopes))
+	assert.Equal(t, DefaultLookbackMonths, consent.LookbackMonths)
+	assert.Equal(t, "Acme Credit", consent.PartnerName)
+
+	_, err = s.SetPartnerActive(ctx, partner.ID, false)
+	require.NoError(t, err)
+	_, err = s.Grant(ctx, userID, &GrantRequest{PartnerID: partner.ID, Scopes: []string{"income"}})
+	assert.ErrorIs(t, err, ErrInvalidConsent, "suspended partners cannot be granted consent")
+}
+
+func TestPartnerReadsOnlyItsConsentsInForce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 14, 12, 0, 0, 0, time.UTC)
+	s, repo, partner, key := newTestService(t, now)
+	other, otherKey, err := s.CreatePartner(ctx, "Other Lender", "", nil)
+	require.NoError(t, err)
+	repo.flows = []domain.MonthlyFlow{
+		flow(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), "MWK", 500, 500, 0),
+		// The current month is left out
+		flow(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), "MWK", 900, 900, 0),
+	}
+	userID := uuid.New()
+	consent, err := s.Grant(ctx, userID, &GrantRequest{PartnerID: partner.ID, Scopes: []string{"income"}, LookbackMonths: 2, PartnerReference: "LOAN-7"})
+	require.NoError(t, err)
+
+	_, err = s.Authenticate(ctx, "kyd_partner_bogus")
+	assert.ErrorIs(t, err, ErrInvalidPartnerKey)
+	caller, err := s.Authenticate(ctx, key)
+	require.NoError(t, err)
+	otherCaller, err := s.Authenticate(ctx, otherKey)
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, otherCaller.ID)
+
+	listed, err := s.PartnerConsents(ctx, caller, "LOAN-7")
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, uuid.Nil, listed[0].UserID, "partners do not learn the user's ID")
+	listed, err = s.PartnerConsents(ctx, otherCaller, "")
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+
+	_, err = s.Summary(ctx, otherCaller, consent.ID, "10.0.0.2")
+	assert.ErrorIs(t, err, ErrConsentNotFound)
+	summary, err := s.Summary(ctx, caller, consent.ID, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), summary.PeriodStart)
+	assert.Equal(t, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), summary.PeriodEnd)
+	require.Len(t, summary.Currencies, 1)
+	assert.Equal(t, "500", summary.Currencies[0].Income.Total.String())
+
+	log, total, err := s.AccessLog(ctx, userID, &consent.ID, 50, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	assert.Equal(t, partner.ID, log[0].PartnerID)
+	assert.Equal(t, "10.0.0.1", log[0].IPAddress)
+	_, _, err = s.AccessLog(ctx, uuid.New(), &consent.ID, 50, 0)
+	assert.ErrorIs(t, err, ErrConsentNotFound)
+
+	require.NoError(t, s.Revoke(ctx, userID, consent.ID))
+	_, err = s.Summary(ctx, caller, consent.ID, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrConsentInactive)
+	assert.ErrorIs(t, s.Revoke(ctx, userID, consent.ID), ErrConsentNotFound)
+
+	_, err = s.SetPartnerActive(ctx, partner.ID, false)
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, key)
+	assert.ErrorIs(t, err, ErrInvalidPartnerKey, "a suspended partner's key stops working")
+}
@@ -0,0 +1,91 @@
+package developer
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/webhook"
+
+	"github.com/google/uuid"
+)
+
+// APIVersion is the version of the public API the portal documents.
+const APIVersion = "v1"
+
+// Docs is the metadata the portal renders its reference pages from.
+type Docs struct {
+	Version        string            `json:"version"`
+	BaseURL        string            `json:"base_url,omitempty"`
+	Authentication []AuthScheme      `json:"authentication"`
+	Environments   []EnvironmentDoc  `json:"environments"`
+	Webhooks       WebhookDoc        `json:"webhooks"`
+	Pagination     PaginationDoc     `json:"pagination"`
+	Headers        map[string]string `json:"headers"`
+}
+
+type AuthScheme struct {
+	Header      string `json:"header"`
+	Format      string `json:"format"`
+	Description string `json:"description"`
+}
+
+// EnvironmentDoc is one API environment and whether the account has it on.
+type EnvironmentDoc struct {
+	Name      domain.APIEnvironment `json:"name"`
+	KeyPrefix string                `json:"key_prefix"`
+	Enabled   bool                  `json:"enabled"`
+}
+
+type WebhookDoc struct {
+	Events          []domain.WebhookEventType `json:"events"`
+	SignatureHeader string                    `json:"signature_header"`
+	EventHeader     string                    `json:"event_header"`
+	EventIDHeader   string                    `json:"event_id_header"`
+	DeliveryHeader  string                    `json:"delivery_header"`
+	ReplayOfHeader  string                    `json:"replay_of_header"`
+	TestHeader      string                    `json:"test_header"`
+	// ToleranceSeconds is how old a signature timestamp receivers should
+	// accept.
+	ToleranceSeconds int `json:"tolerance_seconds"`
+}
+
+type PaginationDoc struct {
+	DefaultLimit int `json:"default_limit"`
+	MaxLimit     int `json:"max_limit"`
+}
+
+// Docs describes the API as owner sees it. baseURL is where the API is
+// served, when known.
+func (s *Service) Docs(ctx context.Context, owner uuid.UUID, baseURL string) (*Docs, error) {
+	env, err := s.repo.GetEnvironments(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return &Docs{
+		Version: APIVersion,
+		BaseURL: baseURL,
+		Authentication: []AuthScheme{
+			{Header: "X-API-Key", Format: "kyd_live_... or kyd_test_...", Description: "API key issued to the account; calls act as the account"},
+			{Header: "Authorization", Format: "Bearer <access token>", Description: "Access token from /api/v1/auth/login"},
+		},
+		Environments: []EnvironmentDoc{
+			{Name: domain.APIEnvironmentProduction, KeyPrefix: "kyd_live_", Enabled: env.Production},
+			{Name: domain.APIEnvironmentSandbox, KeyPrefix: "kyd_test_", Enabled: env.Sandbox},
+		},
+		Webhooks: WebhookDoc{
+			Events:           domain.WebhookEventTypes,
+			SignatureHeader:  webhook.HeaderSignature,
+			EventHeader:      webhook.HeaderEvent,
+			EventIDHeader:    webhook.HeaderEventID,
+			DeliveryHeader:   webhook.HeaderDelivery,
+			ReplayOfHeader:   webhook.HeaderReplayOf,
+			TestHeader:       webhook.HeaderTest,
+			ToleranceSeconds: int(webhook.DefaultTolerance.Seconds()),
+		},
+		Pagination: PaginationDoc{DefaultLimit: 50, MaxLimit: 1000},
+		Headers: map[string]string{
+			"Idempotency-Key": "Makes a POST safe to retry",
+			"X-Request-ID":    "Returned on every response; quote it when reporting a failed call",
+		},
+	}, nil
+}
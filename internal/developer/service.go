@@ -0,0 +1,208 @@
+// Package developer serves the data behind the partner developer portal:
+// how each of an account's API keys is used and failing, how its webhook
+// endpoints are being delivered to, which API environments it has switched
+// on, and what the API offers. Everything is scoped to one account, the
+// owner of the keys and endpoints.
+package developer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+var ErrKeyNotFound = errors.New("api key not found")
+
+const (
+	// DefaultDays is the period statistics cover unless asked otherwise.
+	DefaultDays = 30
+	// MaxDays bounds the period statistics can cover.
+	MaxDays = 90
+	// maxErrorSamples bounds how many failed calls are listed per key.
+	maxErrorSamples = 50
+)
+
+// Keys lists the API keys issued to an account. Satisfied by
+// auth.APIKeyService.
+type Keys interface {
+	ListOwnerKeys(ctx context.Context, owner uuid.UUID) ([]domain.APIKey, error)
+}
+
+// Repository keeps key usage, failed-call samples and environments.
+type Repository interface {
+	APIKeyUsage(ctx context.Context, keyID uuid.UUID, since time.Time) ([]domain.APIKeyUsageDay, error)
+	APIKeyUsageTotals(ctx context.Context, owner uuid.UUID, since time.Time) (map[uuid.UUID]domain.APIKeyUsageDay, error)
+	ListAPIKeyErrors(ctx context.Context, keyID uuid.UUID, limit int) ([]domain.APIKeyError, error)
+	GetEnvironments(ctx context.Context, owner uuid.UUID) (*domain.DeveloperEnvironments, error)
+	SetEnvironments(ctx context.Context, owner uuid.UUID, env *domain.DeveloperEnvironments) error
+}
+
+// WebhookSummaries sums the deliveries to an account's endpoints.
+type WebhookSummaries interface {
+	SummarizeDeliveries(ctx context.Context, owner uuid.UUID, since time.Time) ([]domain.WebhookDeliverySummary, error)
+}
+
+type Service struct {
+	keys     Keys
+	repo     Repository
+	webhooks WebhookSummaries
+	now      func() time.Time
+}
+
+func NewService(keys Keys, repo Repository, webhooks WebhookSummaries) *Service {
+	return &Service{keys: keys, repo: repo, webhooks: webhooks, now: time.Now}
+}
+
+// KeyOverview is an API key with its usage over the period asked for.
+type KeyOverview struct {
+	domain.APIKey
+	Stats domain.APIKeyStats `json:"stats"`
+}
+
+// KeyUsage is one key's usage per day over the period asked for.
+type KeyUsage struct {
+	KeyID uuid.UUID          `json:"api_key_id"`
+	Since time.Time          `json:"since"`
+	Stats domain.APIKeyStats `json:"stats"`
+	Days  []UsageDay         `json:"days"`
+}
+
+type UsageDay struct {
+	domain.APIKeyUsageDay
+	AvgLatencyMS int64 `json:"avg_latency_ms"`
+}
+
+// since returns the start of the UTC day days-1 days ago, so that days
+// covers today and the days before it.
+func (s *Service) since(days int) time.Time {
+	if days <= 0 {
+		days = DefaultDays
+	}
+	if days > MaxDays {
+		days = MaxDays
+	}
+	now := s.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+}
+
+// Keys returns owner's keys, newest first, each with its usage over the
+// last days days.
+func (s *Service) Keys(ctx context.Context, owner uuid.UUID, days int) ([]KeyOverview, error) {
+	keys, err := s.keys.ListOwnerKeys(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.repo.APIKeyUsageTotals(ctx, owner, s.since(days))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]KeyOverview, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, KeyOverview{APIKey: k, Stats: stats(totals[k.ID])})
+	}
+	return out, nil
+}
+
+// KeyUsage returns one of owner's keys' usage per day over the last days
+// days.
+func (s *Service) KeyUsage(ctx context.Context, owner, keyID uuid.UUID, days int) (*KeyUsage, error) {
+	if err := s.ownKey(ctx, owner, keyID); err != nil {
+		return nil, err
+	}
+	since := s.since(days)
+	usage, err := s.repo.APIKeyUsage(ctx, keyID, since)
+	if err != nil {
+		return nil, err
+	}
+	out := &KeyUsage{KeyID: keyID, Since: since, Days: make([]UsageDay, 0, len(usage))}
+	var total domain.APIKeyUsageDay
+	for _, d := range usage {
+		out.Days = append(out.Days, UsageDay{APIKeyUsageDay: d, AvgLatencyMS: d.AvgLatencyMS()})
+		total.Requests += d.Requests
+		total.ClientErrors += d.ClientErrors
+		total.ServerErrors += d.ServerErrors
+		total.TotalLatencyMS += d.TotalLatencyMS
+	}
+	out.Stats = stats(total)
+	return out, nil
+}
+
+// KeyErrors returns the latest failed calls made with one of owner's keys.
+func (s *Service) KeyErrors(ctx context.Context, owner, keyID uuid.UUID, limit int) ([]domain.APIKeyError, error) {
+	if err := s.ownKey(ctx, owner, keyID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxErrorSamples {
+		limit = maxErrorSamples
+	}
+	return s.repo.ListAPIKeyErrors(ctx, keyID, limit)
+}
+
+// WebhookSummary sums the deliveries to each of owner's endpoints over the
+// last days days.
+func (s *Service) WebhookSummary(ctx context.Context, owner uuid.UUID, days int) ([]domain.WebhookDeliverySummary, error) {
+	return s.webhooks.SummarizeDeliveries(ctx, owner, s.since(days))
+}
+
+func (s *Service) Environments(ctx context.Context, owner uuid.UUID) (*domain.DeveloperEnvironments, error) {
+	return s.repo.GetEnvironments(ctx, owner)
+}
+
+// EnvironmentsRequest switches environments on or off. Nil fields are left
+// as they are.
+type EnvironmentsRequest struct {
+	Production *bool `json:"production"`
+	Sandbox    *bool `json:"sandbox"`
+}
+
+// SetEnvironments switches owner's environments on or off. Keys of an
+// environment switched off are rejected until it is switched on again.
+func (s *Service) SetEnvironments(ctx context.Context, owner uuid.UUID, req EnvironmentsRequest) (*domain.DeveloperEnvironments, error) {
+	env, err := s.repo.GetEnvironments(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if req.Production != nil {
+		env.Production = *req.Production
+	}
+	if req.Sandbox != nil {
+		env.Sandbox = *req.Sandbox
+	}
+	now := s.now()
+	env.UpdatedAt = &now
+	if err := s.repo.SetEnvironments(ctx, owner, env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// ownKey returns ErrKeyNotFound unless keyID was issued to owner.
+func (s *Service) ownKey(ctx context.Context, owner, keyID uuid.UUID) error {
+	keys, err := s.keys.ListOwnerKeys(ctx, owner)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.ID == keyID {
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+func stats(u domain.APIKeyUsageDay) domain.APIKeyStats {
+	st := domain.APIKeyStats{
+		Requests:     u.Requests,
+		ClientErrors: u.ClientErrors,
+		ServerErrors: u.ServerErrors,
+		AvgLatencyMS: u.AvgLatencyMS(),
+	}
+	if u.Requests > 0 {
+		st.ErrorRate = float64(u.ClientErrors+u.ServerErrors) / float64(u.Requests)
+	}
+	return st
+}
@@ -0,0 +1,154 @@
+package developer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKeys map[uuid.UUID][]domain.APIKey
+
+func (f fakeKeys) ListOwnerKeys(ctx context.Context, owner uuid.UUID) ([]domain.APIKey, error) {
+	return f[owner], nil
+}
+
+type fakeRepo struct {
+	usage  map[uuid.UUID][]domain.APIKeyUsageDay
+	errors map[uuid.UUID][]domain.APIKeyError
+	envs   map[uuid.UUID]*domain.DeveloperEnvironments
+	since  time.Time
+	limit  int
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		usage:  map[uuid.UUID][]domain.APIKeyUsageDay{},
+		errors: map[uuid.UUID][]domain.APIKeyError{},
+		envs:   map[uuid.UUID]*domain.DeveloperEnvironments{},
+	}
+}
+
+func (f *fakeRepo) APIKeyUsage(ctx context.Context, keyID uuid.UUID, since time.Time) ([]domain.APIKeyUsageDay, error) {
+	f.since = since
+	return f.usage[keyID], nil
+}
+
+func (f *fakeRepo) APIKeyUsageTotals(ctx context.Context, owner uuid.UUID, since time.Time) (map[uuid.UUID]domain.APIKeyUsageDay, error) {
+	f.since = since
+	totals := map[uuid.UUID]domain.APIKeyUsageDay{}
+	for id, days := range f.usage {
+		var t domain.APIKeyUsageDay
+		for _, d := range days {
+			t.Requests += d.Requests
+			t.ClientErrors += d.ClientErrors
+			t.ServerErrors += d.ServerErrors
+			t.TotalLatencyMS += d.TotalLatencyMS
+		}
+		totals[id] = t
+	}
+	return totals, nil
+}
+
+func (f *fakeRepo) ListAPIKeyErrors(ctx context.Context, keyID uuid.UUID, limit int) ([]domain.APIKeyError, error) {
+	f.limit = limit
+	return f.errors[keyID], nil
+}
+
+func (f *fakeRepo) GetEnvironments(ctx context.Context, owner uuid.UUID) (*domain.DeveloperEnvironments, error) {
+	if env, ok := f.envs[owner]; ok {
+		cp := *env
+		return &cp, nil
+	}
+	return &domain.DeveloperEnvironments{Production: true, Sandbox: true}, nil
+}
+
+func (f *fakeRepo) SetEnvironments(ctx context.Context, owner uuid.UUID, env *domain.DeveloperEnvironments) error {
+	cp := *env
+	f.envs[owner] = &cp
+	return nil
+}
+
+type fakeWebhooks struct{}
+
+func (fakeWebhooks) SummarizeDeliveries(ctx context.Context, owner uuid.UUID, since time.Time) ([]domain.WebhookDeliverySummary, error) {
+	return nil, nil
+}
+
+func testService(keys fakeKeys, repo *fakeRepo) *Service {
+	s := NewService(keys, repo, fakeWebhooks{})
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC) }
+	return s
+}
+
+func TestService_KeysWithStats(t *testing.T) {
+	owner := uuid.New()
+	used, unused := uuid.New(), uuid.New()
+	repo := newFakeRepo()
+	repo.usage[used] = []domain.APIKeyUsageDay{
+		{Requests: 8, ClientErrors: 1, TotalLatencyMS: 400},
+		{Requests: 2, ServerErrors: 1, TotalLatencyMS: 200},
+	}
+	s := testService(fakeKeys{owner: {{ID: used}, {ID: unused}}}, repo)
+
+	keys, err := s.Keys(context.Background(), owner, 7)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, domain.APIKeyStats{Requests: 10, ClientErrors: 1, ServerErrors: 1, ErrorRate: 0.2, AvgLatencyMS: 60}, keys[0].Stats)
+	assert.Equal(t, domain.APIKeyStats{}, keys[1].Stats)
+	assert.Equal(t, time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC), repo.since, "7 days include today")
+
+	_, err = s.Keys(context.Background(), owner, 365)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(MaxDays-1)), repo.since)
+}
+
+func TestService_KeyUsageScopedToOwner(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	keyID := uuid.New()
+	repo := newFakeRepo()
+	repo.usage[keyID] = []domain.APIKeyUsageDay{{Requests: 4, TotalLatencyMS: 100}}
+	repo.errors[keyID] = []domain.APIKeyError{{APIKeyID: keyID, Status: 500}}
+	s := testService(fakeKeys{owner: {{ID: keyID}}}, repo)
+
+	usage, err := s.KeyUsage(context.Background(), owner, keyID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), usage.Stats.Requests)
+	require.Len(t, usage.Days, 1)
+	assert.Equal(t, int64(25), usage.Days[0].AvgLatencyMS)
+
+	_, err = s.KeyUsage(context.Background(), other, keyID, 0)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	samples, err := s.KeyErrors(context.Background(), owner, keyID, 500)
+	require.NoError(t, err)
+	assert.Len(t, samples, 1)
+	assert.Equal(t, maxErrorSamples, repo.limit)
+
+	_, err = s.KeyErrors(context.Background(), other, keyID, 10)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestService_SetEnvironmentsLeavesOmittedFields(t *testing.T) {
+	owner := uuid.New()
+	repo := newFakeRepo()
+	s := testService(fakeKeys{}, repo)
+
+	off := false
+	env, err := s.SetEnvironments(context.Background(), owner, EnvironmentsRequest{Sandbox: &off})
+	require.NoError(t, err)
+	assert.True(t, env.Production)
+	assert.False(t, env.Sandbox)
+	require.NotNil(t, env.UpdatedAt)
+
+	docs, err := s.Docs(context.Background(), owner, "https://api.example.com")
+	require.NoError(t, err)
+	require.Len(t, docs.Environments, 2)
+	assert.True(t, docs.Environments[0].Enabled)
+	assert.False(t, docs.Environments[1].Enabled)
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AMLMatch is one sanctions or PEP list entry a screened name resembled.
+type AMLMatch struct {
+	List      string   `json:"list"`
+	Kind      string   `json:"kind"`
+	EntryID   string   `json:"entry_id"`
+	Name      string   `json:"name"`
+	Score     float64  `json:"score"`
+	Programs  []string `json:"programs,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+}
+
+type AMLMatches []AMLMatch
+
+func (m AMLMatches) Value() (driver.Value, error) {
+	if m == nil {
+		m = AMLMatches{}
+	}
+	return json.Marshal(m)
+}
+
+func (m *AMLMatches) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, m)
+}
+
+// AMLScreening is one screening of a user against sanctions and PEP lists.
+// The latest is the AML part of the user's KYC profile; it is never shown
+// to the user.
+type AMLScreening struct {
+	ID           uuid.UUID      `json:"id" db:"id"`
+	UserID       uuid.UUID      `json:"user_id" db:"user_id"`
+	Provider     string         `json:"provider" db:"provider"`
+	ScreenedName string         `json:"screened_name" db:"screened_name"`
+	Countries    pq.StringArray `json:"countries" db:"countries"`
+	SanctionHit  bool           `json:"sanction_hit" db:"sanction_hit"`
+	PEP          bool           `json:"pep" db:"pep"`
+	// CountryHit means the user is tied to a comprehensively sanctioned
+	// country.
+	CountryHit bool       `json:"country_hit" db:"country_hit"`
+	RiskScore  int        `json:"risk_score" db:"risk_score"`
+	Matches    AMLMatches `json:"matches" db:"matches"`
+	// A compliance officer may clear a sanctions hit found to be a false
+	// positive.
+	ClearedBy   *uuid.UUID `json:"cleared_by,omitempty" db:"cleared_by"`
+	ClearedAt   *time.Time `json:"cleared_at,omitempty" db:"cleared_at"`
+	ClearReason *string    `json:"clear_reason,omitempty" db:"clear_reason"`
+	ScreenedAt  time.Time  `json:"screened_at" db:"screened_at"`
+}
+
+// BlocksVerification reports whether the screening forbids verifying the
+// user: an uncleared sanctions hit.
+func (s *AMLScreening) BlocksVerification() bool {
+	return s.SanctionHit && s.ClearedAt == nil
+}
@@ -1,6 +1,10 @@
 package domain
 
-import "github.com/shopspring/decimal"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 // TransactionVolume represents aggregated transaction volume for a period
 type TransactionVolume struct {
@@ -22,3 +26,26 @@ type SystemStats struct {
 	TotalFees         decimal.Decimal `json:"total_fees" db:"total_fees"`
 	ActiveUsers       int64           `json:"active_users" db:"active_users"`
 }
+
+// FunnelDay is the precomputed onboarding funnel for users who registered in
+// one corridor on one day. Corridors are keyed by the user's country, the
+// sending side of their payments. Later stages count members of that
+// registration cohort who have reached them since.
+type FunnelDay struct {
+	Day          time.Time `json:"day" db:"day"`
+	Corridor     string    `json:"corridor" db:"corridor"`
+	Registered   int       `json:"registered" db:"registered"`
+	KYCVerified  int       `json:"kyc_verified" db:"kyc_verified"`
+	FirstPayment int       `json:"first_payment" db:"first_payment"`
+	ComputedAt   time.Time `json:"computed_at" db:"computed_at"`
+}
+
+// RetentionCell counts the senders of a monthly cohort, grouped by the month
+// of their first completed payment, who sent again in ActivityMonth.
+type RetentionCell struct {
+	CohortMonth   time.Time `json:"cohort_month" db:"cohort_month"`
+	ActivityMonth time.Time `json:"activity_month" db:"activity_month"`
+	Corridor      string    `json:"corridor" db:"corridor"`
+	Senders       int       `json:"senders" db:"senders"`
+	ComputedAt    time.Time `json:"computed_at" db:"computed_at"`
+}
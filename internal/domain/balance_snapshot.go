@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// WalletBalanceAt is a wallet's available balance at a past moment, rebuilt
+// from the latest balance snapshot taken before it and the ledger entries
+// written between the two. Without a snapshot the wallet's whole ledger is
+// replayed from zero.
+type WalletBalanceAt struct {
+	WalletID uuid.UUID       `json:"wallet_id" db:"wallet_id"`
+	Currency Currency        `json:"currency" db:"currency"`
+	Balance  decimal.Decimal `json:"available_balance" db:"balance"`
+	// SnapshotAt is when the snapshot replayed from was taken, and
+	// SnapshotReserved the funds then held for pending payments.
+	SnapshotAt       *time.Time       `json:"snapshot_at,omitempty" db:"snapshot_at"`
+	SnapshotBalance  *decimal.Decimal `json:"snapshot_balance,omitempty" db:"snapshot_balance"`
+	SnapshotReserved *decimal.Decimal `json:"snapshot_reserved_balance,omitempty" db:"snapshot_reserved"`
+	EntriesReplayed  int              `json:"entries_replayed" db:"entries_replayed"`
+	// LastEntryAt is when the last ledger entry up to the moment was
+	// written, and EntryBalance the balance it recorded.
+	LastEntryAt  *time.Time       `json:"last_entry_at,omitempty" db:"last_entry_at"`
+	EntryBalance *decimal.Decimal `json:"entry_balance,omitempty" db:"entry_balance"`
+	// Consistent is false when the rebuilt balance disagrees with the one
+	// the last entry recorded, which calls for a ledger replay.
+	Consistent bool `json:"consistent" db:"-"`
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// UsageMetric is something billed by how much of it an account used.
+type UsageMetric string
+
+const (
+	// UsageAPICalls counts accepted calls made with the account's API keys.
+	UsageAPICalls UsageMetric = "api_calls"
+	// UsagePayoutVolume sums payments sent by accounts on plans with API
+	// access, per currency.
+	UsagePayoutVolume UsageMetric = "payout_volume"
+)
+
+// UsageTotal is an account's use of one metric in one billing month.
+// Currency is empty for metrics that are counts rather than amounts.
+type UsageTotal struct {
+	UserID      uuid.UUID       `json:"user_id" db:"user_id"`
+	Metric      UsageMetric     `json:"metric" db:"metric"`
+	Currency    Currency        `json:"currency,omitempty" db:"currency"`
+	PeriodStart time.Time       `json:"period_start" db:"period_start"`
+	Quantity    decimal.Decimal `json:"quantity" db:"quantity"`
+}
+
+// BillingCharge is a one-off amount waiting for the account's next invoice,
+// such as the proration of a plan upgrade.
+type BillingCharge struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	UserID      uuid.UUID       `json:"user_id" db:"user_id"`
+	Amount      decimal.Decimal `json:"amount" db:"amount"`
+	Currency    Currency        `json:"currency" db:"currency"`
+	Description string          `json:"description" db:"description"`
+	InvoiceID   *uuid.UUID      `json:"invoice_id,omitempty" db:"invoice_id"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusOpen          InvoiceStatus = "open"
+	InvoiceStatusPaid          InvoiceStatus = "paid"
+	InvoiceStatusPastDue       InvoiceStatus = "past_due"
+	InvoiceStatusUncollectible InvoiceStatus = "uncollectible"
+)
+
+// CollectionMethod is how an invoice gets paid.
+type CollectionMethod string
+
+const (
+	// CollectionWallet debits the account's wallet in the invoice currency.
+	CollectionWallet CollectionMethod = "wallet"
+	// CollectionExternal waits for a payment made outside the platform,
+	// such as a bank transfer, to be recorded by an admin.
+	CollectionExternal CollectionMethod = "external"
+)
+
+// InvoiceLineKind says what an invoice line bills for.
+type InvoiceLineKind string
+
+const (
+	InvoiceLinePlanFee      InvoiceLineKind = "plan_fee"
+	InvoiceLineCharge       InvoiceLineKind = "charge"
+	InvoiceLineAPICalls     InvoiceLineKind = "api_calls"
+	InvoiceLinePayoutVolume InvoiceLineKind = "payout_volume"
+)
+
+// Invoice bills one account for one calendar month in one currency.
+type Invoice struct {
+	ID               uuid.UUID        `json:"id" db:"id"`
+	Number           string           `json:"number" db:"number"`
+	UserID           uuid.UUID        `json:"user_id" db:"user_id"`
+	Currency         Currency         `json:"currency" db:"currency"`
+	PeriodStart      time.Time        `json:"period_start" db:"period_start"`
+	PeriodEnd        time.Time        `json:"period_end" db:"period_end"`
+	Total            decimal.Decimal  `json:"total" db:"total"`
+	Status           InvoiceStatus    `json:"status" db:"status"`
+	CollectionMethod CollectionMethod `json:"collection_method" db:"collection_method"`
+	DueAt            time.Time        `json:"due_at" db:"due_at"`
+	// Attempts counts failed wallet debits; NextAttemptAt is when the
+	// next one is due, or nil when none is scheduled.
+	Attempts         int        `json:"attempts" db:"attempts"`
+	NextAttemptAt    *time.Time `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastError        string     `json:"last_error,omitempty" db:"last_error"`
+	PaidAt           *time.Time `json:"paid_at,omitempty" db:"paid_at"`
+	PaymentReference string     `json:"payment_reference,omitempty" db:"payment_reference"`
+	TransactionID    *uuid.UUID `json:"transaction_id,omitempty" db:"transaction_id"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+
+	Lines []InvoiceLine `json:"lines,omitempty" db:"-"`
+}
+
+// IsCollectible reports whether the invoice still awaits payment.
+func (i *Invoice) IsCollectible() bool {
+	return i.Status == InvoiceStatusOpen || i.Status == InvoiceStatusPastDue
+}
+
+type InvoiceLine struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	InvoiceID   uuid.UUID       `json:"invoice_id" db:"invoice_id"`
+	Kind        InvoiceLineKind `json:"kind" db:"kind"`
+	Description string          `json:"description" db:"description"`
+	Quantity    decimal.Decimal `json:"quantity" db:"quantity"`
+	UnitPrice   decimal.Decimal `json:"unit_price" db:"unit_price"`
+	Amount      decimal.Decimal `json:"amount" db:"amount"`
+}
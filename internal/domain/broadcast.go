@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type BroadcastStatus string
+
+const (
+	BroadcastStatusScheduled BroadcastStatus = "scheduled"
+	BroadcastStatusSending   BroadcastStatus = "sending"
+	BroadcastStatusSent      BroadcastStatus = "sent"
+	BroadcastStatusCancelled BroadcastStatus = "cancelled"
+)
+
+type BroadcastDeliveryStatus string
+
+const (
+	BroadcastDeliveryDelivered BroadcastDeliveryStatus = "delivered"
+	BroadcastDeliveryFailed    BroadcastDeliveryStatus = "failed"
+)
+
+// BroadcastSegment selects the users a broadcast is sent to.
+type BroadcastSegment = SegmentCriteria
+
+// Broadcast is an operator-composed message delivered to a segment of users.
+type Broadcast struct {
+	ID          uuid.UUID        `json:"id" db:"id"`
+	Title       string           `json:"title" db:"title"`
+	Message     string           `json:"message" db:"message"`
+	Segment     BroadcastSegment `json:"segment" db:"segment"`
+	SegmentID   *uuid.UUID       `json:"segment_id,omitempty" db:"segment_id"` // saved segment the criteria were copied from
+	Channels    pq.StringArray   `json:"channels" db:"channels"`
+	Status      BroadcastStatus  `json:"status" db:"status"`
+	ScheduledAt time.Time        `json:"scheduled_at" db:"scheduled_at"`
+	StartedAt   *time.Time       `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedBy   *uuid.UUID       `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// BroadcastDelivery records the outcome of a broadcast for one recipient.
+type BroadcastDelivery struct {
+	ID             uuid.UUID               `json:"id" db:"id"`
+	BroadcastID    uuid.UUID               `json:"broadcast_id" db:"broadcast_id"`
+	UserID         uuid.UUID               `json:"user_id" db:"user_id"`
+	NotificationID *uuid.UUID              `json:"notification_id,omitempty" db:"notification_id"`
+	Status         BroadcastDeliveryStatus `json:"status" db:"status"`
+	Error          *string                 `json:"error,omitempty" db:"error"`
+	CreatedAt      time.Time               `json:"created_at" db:"created_at"`
+}
+
+// BroadcastStats summarises delivery and read rates for a broadcast.
+type BroadcastStats struct {
+	Recipients   int     `json:"recipients" db:"recipients"`
+	Delivered    int     `json:"delivered" db:"delivered"`
+	Failed       int     `json:"failed" db:"failed"`
+	Read         int     `json:"read" db:"read"`
+	DeliveryRate float64 `json:"delivery_rate"`
+	ReadRate     float64 `json:"read_rate"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BulkFileStatus string
+
+const (
+	BulkFileStatusRejected  BulkFileStatus = "rejected"
+	BulkFileStatusAccepted  BulkFileStatus = "accepted"
+	BulkFileStatusProcessed BulkFileStatus = "processed"
+)
+
+// BulkFile is a payment file received from a corporate, kept byte for byte
+// with its SHA-256 so that what was instructed can be proven in a dispute.
+type BulkFile struct {
+	ID            uuid.UUID      `json:"id" db:"id"`
+	UserID        uuid.UUID      `json:"user_id" db:"user_id"`
+	FileName      string         `json:"file_name" db:"file_name"`
+	Format        string         `json:"format" db:"format"`
+	FormatVersion string         `json:"format_version" db:"format_version"`
+	Reference     string         `json:"reference,omitempty" db:"reference"`
+	SHA256        string         `json:"sha256" db:"sha256"`
+	Size          int64          `json:"size" db:"size"`
+	Content       []byte         `json:"-" db:"content"`
+	Status        BulkFileStatus `json:"status" db:"status"`
+	PaymentCount  int            `json:"payment_count" db:"payment_count"`
+	Errors        JSONPayload    `json:"errors,omitempty" db:"errors"`
+	Result        JSONPayload    `json:"result,omitempty" db:"result"`
+	ReceivedAt    time.Time      `json:"received_at" db:"received_at"`
+	ProcessedAt   *time.Time     `json:"processed_at,omitempty" db:"processed_at"`
+}
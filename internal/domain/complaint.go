@@ -0,0 +1,169 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ComplaintCategory is what a complaint is about.
+type ComplaintCategory string
+
+const (
+	ComplaintUnauthorisedTransaction ComplaintCategory = "unauthorised_transaction"
+	ComplaintFailedTransaction       ComplaintCategory = "failed_transaction"
+	ComplaintDelayedTransaction      ComplaintCategory = "delayed_transaction"
+	// ComplaintCharges covers fees and exchange rates.
+	ComplaintCharges       ComplaintCategory = "charges"
+	ComplaintAccountAccess ComplaintCategory = "account_access"
+	ComplaintFraud         ComplaintCategory = "fraud"
+	ComplaintService       ComplaintCategory = "service_quality"
+	ComplaintOther         ComplaintCategory = "other"
+)
+
+func (c ComplaintCategory) IsValid() bool {
+	switch c {
+	case ComplaintUnauthorisedTransaction, ComplaintFailedTransaction, ComplaintDelayedTransaction,
+		ComplaintCharges, ComplaintAccountAccess, ComplaintFraud, ComplaintService, ComplaintOther:
+		return true
+	}
+	return false
+}
+
+// ComplaintChannel is how a complaint reached us.
+type ComplaintChannel string
+
+const (
+	ComplaintChannelApp    ComplaintChannel = "app"
+	ComplaintChannelPhone  ComplaintChannel = "phone"
+	ComplaintChannelEmail  ComplaintChannel = "email"
+	ComplaintChannelAgent  ComplaintChannel = "agent"
+	ComplaintChannelLetter ComplaintChannel = "letter"
+)
+
+func (c ComplaintChannel) IsValid() bool {
+	switch c {
+	case ComplaintChannelApp, ComplaintChannelPhone, ComplaintChannelEmail, ComplaintChannelAgent, ComplaintChannelLetter:
+		return true
+	}
+	return false
+}
+
+type ComplaintStatus string
+
+const (
+	ComplaintReceived     ComplaintStatus = "received"
+	ComplaintAcknowledged ComplaintStatus = "acknowledged"
+	ComplaintResolved     ComplaintStatus = "resolved"
+)
+
+// ComplaintOutcome is how a resolved complaint was decided.
+type ComplaintOutcome string
+
+const (
+	ComplaintUpheld          ComplaintOutcome = "upheld"
+	ComplaintPartiallyUpheld ComplaintOutcome = "partially_upheld"
+	ComplaintNotUpheld       ComplaintOutcome = "not_upheld"
+	ComplaintWithdrawn       ComplaintOutcome = "withdrawn"
+)
+
+func (o ComplaintOutcome) IsValid() bool {
+	switch o {
+	case ComplaintUpheld, ComplaintPartiallyUpheld, ComplaintNotUpheld, ComplaintWithdrawn:
+		return true
+	}
+	return false
+}
+
+// ComplaintRootCause codes why a complaint arose, once it is resolved.
+type ComplaintRootCause string
+
+const (
+	RootCauseSystemError     ComplaintRootCause = "system_error"
+	RootCauseProcessingError ComplaintRootCause = "processing_error"
+	RootCauseStaffConduct    ComplaintRootCause = "staff_conduct"
+	RootCauseThirdParty      ComplaintRootCause = "third_party"
+	RootCauseDisclosure      ComplaintRootCause = "disclosure"
+	RootCauseFraud           ComplaintRootCause = "fraud"
+	RootCauseCustomerError   ComplaintRootCause = "customer_error"
+	RootCausePolicy          ComplaintRootCause = "policy"
+	// RootCauseNoFault is for complaints where nothing went wrong.
+	RootCauseNoFault ComplaintRootCause = "no_fault"
+)
+
+func (r ComplaintRootCause) IsValid() bool {
+	switch r {
+	case RootCauseSystemError, RootCauseProcessingError, RootCauseStaffConduct, RootCauseThirdParty,
+		RootCauseDisclosure, RootCauseFraud, RootCauseCustomerError, RootCausePolicy, RootCauseNoFault:
+		return true
+	}
+	return false
+}
+
+// Complaint is an expression of dissatisfaction recorded in the complaints
+// register. Unlike a dispute it need not concern a transaction, nor ask for
+// money back; TransactionID and UserID are set where one is involved.
+// People without an account are known by ComplainantName and
+// ComplainantContact.
+type Complaint struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Reference string     `json:"reference" db:"reference"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	// TransactionID is the transaction complained about, if any.
+	TransactionID      *uuid.UUID        `json:"transaction_id,omitempty" db:"transaction_id"`
+	ComplainantName    string            `json:"complainant_name,omitempty" db:"complainant_name"`
+	ComplainantContact string            `json:"complainant_contact,omitempty" db:"complainant_contact"`
+	Channel            ComplaintChannel  `json:"channel" db:"channel"`
+	Category           ComplaintCategory `json:"category" db:"category"`
+	// Language is the one the complainant wrote in and is answered in.
+	Language    string           `json:"language" db:"language"`
+	Subject     string           `json:"subject" db:"subject"`
+	Description string           `json:"description" db:"description"`
+	Amount      *decimal.Decimal `json:"amount,omitempty" db:"amount"`
+	Currency    string           `json:"currency,omitempty" db:"currency"`
+	Status      ComplaintStatus  `json:"status" db:"status"`
+	ReceivedAt  time.Time        `json:"received_at" db:"received_at"`
+	// AcknowledgeBy and ResolveBy are the service levels the complaint is
+	// held to, set when it is received.
+	AcknowledgeBy  time.Time           `json:"acknowledge_by" db:"acknowledge_by"`
+	AcknowledgedAt *time.Time          `json:"acknowledged_at,omitempty" db:"acknowledged_at"`
+	ResolveBy      time.Time           `json:"resolve_by" db:"resolve_by"`
+	ResolvedAt     *time.Time          `json:"resolved_at,omitempty" db:"resolved_at"`
+	Outcome        *ComplaintOutcome   `json:"outcome,omitempty" db:"outcome"`
+	RootCause      *ComplaintRootCause `json:"root_cause,omitempty" db:"root_cause"`
+	Resolution     string              `json:"resolution,omitempty" db:"resolution"`
+	RedressAmount  *decimal.Decimal    `json:"redress_amount,omitempty" db:"redress_amount"`
+	AssignedTo     *uuid.UUID          `json:"assigned_to,omitempty" db:"assigned_to"`
+	CreatedBy      *uuid.UUID          `json:"created_by,omitempty" db:"created_by"`
+	UpdatedAt      time.Time           `json:"updated_at" db:"updated_at"`
+}
+
+// ComplaintFilter narrows a search of the register. Overdue keeps only
+// complaints past either deadline.
+type ComplaintFilter struct {
+	UserID   *uuid.UUID
+	Status   ComplaintStatus
+	Category ComplaintCategory
+	Overdue  bool
+}
+
+// ComplaintReturnLine is a complaint as reported to the regulator, with the
+// complainant's account type ("" for people without an account) and the
+// transaction's reference number.
+type ComplaintReturnLine struct {
+	Complaint
+	UserType             string `db:"user_type"`
+	TransactionReference string `db:"transaction_reference"`
+}
+
+// ComplaintReturn is the quarterly complaints return to the Reserve Bank of
+// Malawi. Period is a quarter such as "2026-Q3"; Content is the CSV.
+type ComplaintReturn struct {
+	Period      string    `json:"period" db:"period"`
+	PeriodStart time.Time `json:"period_start" db:"period_start"`
+	PeriodEnd   time.Time `json:"period_end" db:"period_end"`
+	Complaints  int       `json:"complaints" db:"complaints"`
+	Content     []byte    `json:"-" db:"content"`
+	GeneratedAt time.Time `json:"generated_at" db:"generated_at"`
+}
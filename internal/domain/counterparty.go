@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+type CounterpartyType string
+
+const (
+	CounterpartySettlementPartner CounterpartyType = "settlement_partner"
+	CounterpartyPayoutProvider    CounterpartyType = "payout_provider"
+	CounterpartyCorrespondentBank CounterpartyType = "correspondent_bank"
+)
+
+type CounterpartyStatus string
+
+const (
+	CounterpartyActive    CounterpartyStatus = "active"
+	CounterpartySuspended CounterpartyStatus = "suspended"
+)
+
+// CounterpartyContact is a person to reach at a counterparty.
+type CounterpartyContact struct {
+	Name  string `json:"name"`
+	Role  string `json:"role,omitempty"`
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+}
+
+type CounterpartyContacts []CounterpartyContact
+
+func (c CounterpartyContacts) Value() (driver.Value, error) {
+	if c == nil {
+		c = CounterpartyContacts{}
+	}
+	return json.Marshal(c)
+}
+
+func (c *CounterpartyContacts) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, c)
+}
+
+// Counterparty is a settlement partner, payout provider or correspondent
+// bank. Settlements in its Corridors go to it while it is active and its
+// prefunding covers them.
+type Counterparty struct {
+	ID      uuid.UUID          `json:"id" db:"id"`
+	Code    string             `json:"code" db:"code"`
+	Name    string             `json:"name" db:"name"`
+	Type    CounterpartyType   `json:"type" db:"type"`
+	Country string             `json:"country" db:"country"`
+	Status  CounterpartyStatus `json:"status" db:"status"`
+	// StatusReason explains the latest suspension or reactivation.
+	StatusReason string         `json:"status_reason,omitempty" db:"status_reason"`
+	Corridors    pq.StringArray `json:"corridors" db:"corridors"` // e.g. "MWK-ZAR"
+	// ExposureLimit caps the value outstanding with the counterparty, in
+	// ExposureCurrency; nil means no limit.
+	ExposureLimit    *decimal.Decimal     `json:"exposure_limit,omitempty" db:"exposure_limit"`
+	ExposureCurrency Currency             `json:"exposure_currency,omitempty" db:"exposure_currency"`
+	Contacts         CounterpartyContacts `json:"contacts" db:"contacts"`
+	Notes            string               `json:"notes,omitempty" db:"notes"`
+	CreatedBy        *uuid.UUID           `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt        time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// Serves reports whether the counterparty is enabled for corridor.
+func (c *Counterparty) Serves(corridor string) bool {
+	for _, cc := range c.Corridors {
+		if cc == corridor {
+			return true
+		}
+	}
+	return false
+}
+
+// CounterpartyDocument is a contract, licence or compliance document kept
+// for a counterparty. The file itself lives at URL.
+type CounterpartyDocument struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	CounterpartyID uuid.UUID  `json:"counterparty_id" db:"counterparty_id"`
+	Kind           string     `json:"kind" db:"kind"` // e.g. "agreement", "licence", "aml_policy"
+	Name           string     `json:"name" db:"name"`
+	URL            string     `json:"url" db:"url"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	UploadedBy     *uuid.UUID `json:"uploaded_by,omitempty" db:"uploaded_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CounterpartyBalance is what a counterparty has prefunded in a currency
+// and not yet drawn for settlements.
+type CounterpartyBalance struct {
+	CounterpartyID uuid.UUID       `json:"counterparty_id" db:"counterparty_id"`
+	Currency       Currency        `json:"currency" db:"currency"`
+	Balance        decimal.Decimal `json:"balance" db:"balance"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type PrefundingEntryKind string
+
+const (
+	// PrefundingDeposit is funding the counterparty sent us.
+	PrefundingDeposit PrefundingEntryKind = "deposit"
+	// PrefundingDrawdown is funding used by a settlement.
+	PrefundingDrawdown PrefundingEntryKind = "drawdown"
+	// PrefundingReturn gives back a drawdown whose settlement failed.
+	PrefundingReturn PrefundingEntryKind = "return"
+	// PrefundingWithdrawal is funding returned to the counterparty.
+	PrefundingWithdrawal PrefundingEntryKind = "withdrawal"
+)
+
+// PrefundingEntry is one movement of a counterparty's prefunding. Amount is
+// signed: deposits and returns add, drawdowns and withdrawals subtract.
+type PrefundingEntry struct {
+	ID             uuid.UUID           `json:"id" db:"id"`
+	CounterpartyID uuid.UUID           `json:"counterparty_id" db:"counterparty_id"`
+	Currency       Currency            `json:"currency" db:"currency"`
+	Kind           PrefundingEntryKind `json:"kind" db:"kind"`
+	Amount         decimal.Decimal     `json:"amount" db:"amount"`
+	BalanceAfter   decimal.Decimal     `json:"balance_after" db:"balance_after"`
+	Reference      string              `json:"reference,omitempty" db:"reference"`
+	SettlementID   *uuid.UUID          `json:"settlement_id,omitempty" db:"settlement_id"`
+	CreatedBy      *uuid.UUID          `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+}
+
+// CounterpartyOutstanding is the value of a counterparty's settlements in
+// one currency that have drawn its prefunding but not yet completed.
+type CounterpartyOutstanding struct {
+	CounterpartyID uuid.UUID       `json:"counterparty_id" db:"counterparty_id"`
+	Currency       Currency        `json:"currency" db:"currency"`
+	Amount         decimal.Decimal `json:"amount" db:"amount"`
+	Settlements    int             `json:"settlements" db:"settlements"`
+}
+
+type ExposureLevel string
+
+const (
+	ExposureOK ExposureLevel = "ok"
+	// ExposureWarning is at or past the soft alert threshold.
+	ExposureWarning ExposureLevel = "warning"
+	// ExposureBreached is at the hard limit; routing to the counterparty
+	// is paused until settlements complete.
+	ExposureBreached ExposureLevel = "breached"
+)
+
+// CounterpartyExposure is the value outstanding with a counterparty. With
+// a limit, Exposure is Outstanding converted into the limit's currency;
+// without one it is left unset when Outstanding spans several currencies.
+type CounterpartyExposure struct {
+	CounterpartyID uuid.UUID                  `json:"counterparty_id"`
+	Code           string                     `json:"code"`
+	Name           string                     `json:"name"`
+	Status         CounterpartyStatus         `json:"status"`
+	Outstanding    []*CounterpartyOutstanding `json:"outstanding"`
+	Settlements    int                        `json:"settlements"`
+	Currency       Currency                   `json:"currency,omitempty"`
+	Exposure       *decimal.Decimal           `json:"exposure,omitempty"`
+	Limit          *decimal.Decimal           `json:"limit,omitempty"`
+	// Utilisation is Exposure as a share of Limit.
+	Utilisation *decimal.Decimal `json:"utilisation,omitempty"`
+	Level       ExposureLevel    `json:"level"`
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// CreditFacilityStatus is whether a facility can be drawn on.
+type CreditFacilityStatus string
+
+const (
+	// CreditFacilityActive lets payments overdraw the wallet up to the limit.
+	CreditFacilityActive CreditFacilityStatus = "active"
+	// CreditFacilitySuspended stops new drawings; what is owed is still
+	// charged interest and collected.
+	CreditFacilitySuspended CreditFacilityStatus = "suspended"
+	// CreditFacilityClosed ends the facility. It is still collected until
+	// nothing is owed.
+	CreditFacilityClosed CreditFacilityStatus = "closed"
+)
+
+// CreditDelinquency is how long a facility has gone without being repaid.
+type CreditDelinquency string
+
+const (
+	CreditCurrent    CreditDelinquency = "current"
+	CreditPastDue    CreditDelinquency = "past_due"
+	CreditDelinquent CreditDelinquency = "delinquent"
+)
+
+// CreditFacility is an overdraft on one of a customer's wallets. The wallet
+// may go as far as Limit below zero; interest accrues daily on the
+// overdrawn balance at AnnualRate and is collected from the wallet once it
+// is back in credit. OverdrawnSince is when the customer last started to
+// owe anything, which delinquency counts from.
+type CreditFacility struct {
+	ID                uuid.UUID            `json:"id" db:"id"`
+	UserID            uuid.UUID            `json:"user_id" db:"user_id"`
+	WalletID          uuid.UUID            `json:"wallet_id" db:"wallet_id"`
+	Currency          Currency             `json:"currency" db:"currency"`
+	Limit             decimal.Decimal      `json:"limit" db:"credit_limit"`
+	AnnualRate        decimal.Decimal      `json:"annual_rate" db:"annual_rate"`
+	Status            CreditFacilityStatus `json:"status" db:"status"`
+	Delinquency       CreditDelinquency    `json:"delinquency" db:"delinquency"`
+	OverdrawnSince    *time.Time           `json:"overdrawn_since,omitempty" db:"overdrawn_since"`
+	AccruedInterest   decimal.Decimal      `json:"accrued_interest" db:"accrued_interest"`
+	InterestCollected decimal.Decimal      `json:"interest_collected" db:"interest_collected"`
+	// AccruedThrough is the day (UTC) interest has accrued up to, not
+	// including it.
+	AccruedThrough time.Time  `json:"accrued_through" db:"accrued_through"`
+	OpenedBy       *uuid.UUID `json:"opened_by,omitempty" db:"opened_by"`
+	OpenedAt       time.Time  `json:"opened_at" db:"opened_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty" db:"closed_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Balance is the wallet's available balance when the facility was read.
+	Balance decimal.Decimal `json:"balance" db:"balance"`
+}
+
+// Overdrawn is how far below zero the wallet is.
+func (f *CreditFacility) Overdrawn() decimal.Decimal {
+	if f.Balance.IsNegative() {
+		return f.Balance.Neg()
+	}
+	return decimal.Zero
+}
+
+// AvailableCredit is how much more payments may overdraw the wallet.
+func (f *CreditFacility) AvailableCredit() decimal.Decimal {
+	if f.Status != CreditFacilityActive {
+		return decimal.Zero
+	}
+	left := f.Limit.Sub(f.Overdrawn())
+	if left.IsNegative() {
+		return decimal.Zero
+	}
+	return left
+}
+
+// Owed reports whether the customer owes anything on the facility.
+func (f *CreditFacility) Owed() bool {
+	return f.Balance.IsNegative() || f.AccruedInterest.IsPositive()
+}
+
+// CreditFacilityFilter narrows a list of facilities; zero fields match all.
+type CreditFacilityFilter struct {
+	UserID      *uuid.UUID
+	Status      CreditFacilityStatus
+	Delinquency CreditDelinquency
+}
+
+// CreditInterestAccrual is interest accrued on a facility's overdrawn
+// balance over the days in [PeriodStart, PeriodEnd).
+type CreditInterestAccrual struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	FacilityID  uuid.UUID       `json:"facility_id" db:"facility_id"`
+	Currency    Currency        `json:"currency" db:"-"`
+	PeriodStart time.Time       `json:"period_start" db:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end" db:"period_end"`
+	Overdrawn   decimal.Decimal `json:"overdrawn" db:"overdrawn"`
+	AnnualRate  decimal.Decimal `json:"annual_rate" db:"annual_rate"`
+	Amount      decimal.Decimal `json:"amount" db:"amount"`
+	JournalID   *uuid.UUID      `json:"journal_id,omitempty" db:"journal_id"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// CreditInterestCollection is accrued interest taken from a facility's
+// wallet. Amount and TransactionID are filled in when it is posted.
+type CreditInterestCollection struct {
+	FacilityID    uuid.UUID
+	Amount        decimal.Decimal
+	Currency      Currency
+	TransactionID uuid.UUID
+}
+
+// CreditRun counts what one pass over the open facilities did.
+type CreditRun struct {
+	Examined   int `json:"examined"`
+	Accrued    int `json:"accrued"`
+	Collected  int `json:"collected"`
+	PastDue    int `json:"past_due"`
+	Delinquent int `json:"delinquent"`
+	Failed     int `json:"failed"`
+}
+
+// CreditDelinquencyLine sums the facilities in one delinquency state and
+// currency that are not closed, or still owed.
+type CreditDelinquencyLine struct {
+	Delinquency     CreditDelinquency `json:"delinquency" db:"delinquency"`
+	Currency        Currency          `json:"currency" db:"currency"`
+	Facilities      int               `json:"facilities" db:"facilities"`
+	Overdrawn       decimal.Decimal   `json:"overdrawn" db:"overdrawn"`
+	AccruedInterest decimal.Decimal   `json:"accrued_interest" db:"accrued_interest"`
+}
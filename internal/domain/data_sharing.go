@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// DataSharingScope is a part of the transaction summary a user may share
+// with a partner.
+type DataSharingScope string
+
+const (
+	// DataScopeIncome shares money received from others, a proxy for income.
+	DataScopeIncome DataSharingScope = "income"
+	// DataScopeVolume shares the number and value of money in and out.
+	DataScopeVolume DataSharingScope = "volume"
+	// DataScopeRegularity shares how steady income is from month to month.
+	DataScopeRegularity DataSharingScope = "regularity"
+)
+
+// DataSharingScopes are all scopes a consent may grant.
+var DataSharingScopes = []DataSharingScope{DataScopeIncome, DataScopeVolume, DataScopeRegularity}
+
+// DataPartner is a credit-scoring partner users may share transaction
+// summaries with. It calls the partner API with its key, which only reaches
+// the consents users granted it.
+type DataPartner struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Description string     `json:"description" db:"description"`
+	KeyPrefix   string     `json:"key_prefix,omitempty" db:"key_prefix"`
+	KeyHash     string     `json:"-" db:"key_hash"`
+	Active      bool       `json:"active" db:"active"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// DataSharingConsentStatus is where a consent stands at a given time.
+type DataSharingConsentStatus string
+
+const (
+	DataConsentActive  DataSharingConsentStatus = "active"
+	DataConsentExpired DataSharingConsentStatus = "expired"
+	DataConsentRevoked DataSharingConsentStatus = "revoked"
+)
+
+// DataSharingConsent lets a partner read the scoped summary of a user's
+// last LookbackMonths of transactions until it expires or the user revokes
+// it. PartnerReference is what the partner knows the user by, such as a
+// loan application number.
+type DataSharingConsent struct {
+	ID               uuid.UUID      `json:"id" db:"id"`
+	UserID           uuid.UUID      `json:"user_id" db:"user_id"`
+	PartnerID        uuid.UUID      `json:"partner_id" db:"partner_id"`
+	PartnerName      string         `json:"partner_name" db:"partner_name"`
+	Scopes           pq.StringArray `json:"scopes" db:"scopes"`
+	LookbackMonths   int            `json:"lookback_months" db:"lookback_months"`
+	PartnerReference string         `json:"partner_reference,omitempty" db:"partner_reference"`
+	GrantedAt        time.Time      `json:"granted_at" db:"granted_at"`
+	ExpiresAt        time.Time      `json:"expires_at" db:"expires_at"`
+	RevokedAt        *time.Time     `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Status reports whether the consent is in force at now.
+func (c *DataSharingConsent) Status(now time.Time) DataSharingConsentStatus {
+	switch {
+	case c.RevokedAt != nil:
+		return DataConsentRevoked
+	case !now.Before(c.ExpiresAt):
+		return DataConsentExpired
+	}
+	return DataConsentActive
+}
+
+// Allows reports whether the consent grants scope.
+func (c *DataSharingConsent) Allows(scope DataSharingScope) bool {
+	for _, s := range c.Scopes {
+		if s == string(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// DataSharingAccess records a partner reading a user's summary.
+type DataSharingAccess struct {
+	ID          uuid.UUID      `json:"id" db:"id"`
+	ConsentID   uuid.UUID      `json:"consent_id" db:"consent_id"`
+	UserID      uuid.UUID      `json:"user_id" db:"user_id"`
+	PartnerID   uuid.UUID      `json:"partner_id" db:"partner_id"`
+	PartnerName string         `json:"partner_name" db:"partner_name"`
+	Scopes      pq.StringArray `json:"scopes" db:"scopes"`
+	IPAddress   string         `json:"ip_address,omitempty" db:"ip_address"`
+	AccessedAt  time.Time      `json:"accessed_at" db:"accessed_at"`
+}
+
+// MonthlyFlow sums a user's completed transactions in one currency over a
+// calendar month (UTC). Income is money received from other users; inflow
+// adds the user's own deposits. Outflow is money sent to others and
+// withdrawn. Refunds and reversals count as neither.
+type MonthlyFlow struct {
+	Month         time.Time       `db:"month"`
+	Currency      string          `db:"currency"`
+	IncomeCount   int             `db:"income_count"`
+	IncomeAmount  decimal.Decimal `db:"income_amount"`
+	InflowCount   int             `db:"inflow_count"`
+	InflowAmount  decimal.Decimal `db:"inflow_amount"`
+	OutflowCount  int             `db:"outflow_count"`
+	OutflowAmount decimal.Decimal `db:"outflow_amount"`
+}
+
+// CreditSummary is the standardized summary a partner reads under a
+// consent. Each currency the user transacted in has its own figures, and
+// only the consented scopes are filled in.
+type CreditSummary struct {
+	ConsentID        uuid.UUID               `json:"consent_id"`
+	PartnerReference string                  `json:"partner_reference,omitempty"`
+	Scopes           []string                `json:"scopes"`
+	PeriodStart      time.Time               `json:"period_start"`
+	PeriodEnd        time.Time               `json:"period_end"`
+	Months           int                     `json:"months"`
+	Currencies       []CurrencyCreditSummary `json:"currencies"`
+	GeneratedAt      time.Time               `json:"generated_at"`
+}
+
+// CurrencyCreditSummary is a credit summary's figures in one currency.
+type CurrencyCreditSummary struct {
+	Currency   string             `json:"currency"`
+	Income     *IncomeSummary     `json:"income,omitempty"`
+	Volume     *VolumeSummary     `json:"volume,omitempty"`
+	Regularity *RegularitySummary `json:"regularity,omitempty"`
+}
+
+// IncomeSummary describes money received from others per month, months
+// without any counting as zero.
+type IncomeSummary struct {
+	Total          decimal.Decimal `json:"total"`
+	MonthlyAverage decimal.Decimal `json:"monthly_average"`
+	MonthlyMedian  decimal.Decimal `json:"monthly_median"`
+}
+
+// VolumeSummary counts and sums money in and out over the period.
+type VolumeSummary struct {
+	InflowCount         int             `json:"inflow_count"`
+	InflowAmount        decimal.Decimal `json:"inflow_amount"`
+	OutflowCount        int             `json:"outflow_count"`
+	OutflowAmount       decimal.Decimal `json:"outflow_amount"`
+	MonthlyTransactions decimal.Decimal `json:"monthly_transactions"`
+}
+
+// RegularitySummary describes how steady income is. IncomeMonthsRatio is
+// the share of months with any income; IncomeVariation is the coefficient
+// of variation of monthly income, lower being steadier.
+type RegularitySummary struct {
+	MonthsWithIncome  int             `json:"months_with_income"`
+	ActiveMonths      int             `json:"active_months"`
+	IncomeMonthsRatio decimal.Decimal `json:"income_months_ratio"`
+	IncomeVariation   decimal.Decimal `json:"income_variation"`
+}
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyCall is one request authenticated with an API key.
+type APIKeyCall struct {
+	KeyID     uuid.UUID
+	Method    string
+	Path      string
+	Status    int
+	Latency   time.Duration
+	RequestID string
+	// ResponseBody is the start of the response, kept for failed calls.
+	ResponseBody string
+	At           time.Time
+}
+
+// Failed reports whether the call is kept as an error sample.
+func (c APIKeyCall) Failed() bool {
+	return c.Status >= 400
+}
+
+// APIKeyUsageDay is one key's calls on one day (UTC).
+type APIKeyUsageDay struct {
+	Day            time.Time `json:"day" db:"day"`
+	Requests       int64     `json:"requests" db:"requests"`
+	ClientErrors   int64     `json:"client_errors" db:"client_errors"`
+	ServerErrors   int64     `json:"server_errors" db:"server_errors"`
+	TotalLatencyMS int64     `json:"-" db:"total_latency_ms"`
+}
+
+// AvgLatencyMS is the day's mean latency.
+func (d APIKeyUsageDay) AvgLatencyMS() int64 {
+	if d.Requests == 0 {
+		return 0
+	}
+	return d.TotalLatencyMS / d.Requests
+}
+
+// APIKeyStats sums a key's usage over a period.
+type APIKeyStats struct {
+	Requests     int64   `json:"requests"`
+	ClientErrors int64   `json:"client_errors"`
+	ServerErrors int64   `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMS int64   `json:"avg_latency_ms"`
+}
+
+// APIKeyError is a sample of a failed call made with a key.
+type APIKeyError struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	APIKeyID     uuid.UUID `json:"api_key_id" db:"api_key_id"`
+	Method       string    `json:"method" db:"method"`
+	Path         string    `json:"path" db:"path"`
+	Status       int       `json:"status" db:"status"`
+	RequestID    string    `json:"request_id" db:"request_id"`
+	ResponseBody string    `json:"response_body" db:"response_body"`
+	OccurredAt   time.Time `json:"occurred_at" db:"occurred_at"`
+}
+
+// DeveloperEnvironments are the API environments an account has switched
+// on. Keys of a switched-off environment are rejected.
+type DeveloperEnvironments struct {
+	Production bool       `json:"production" db:"production_enabled"`
+	Sandbox    bool       `json:"sandbox" db:"sandbox_enabled"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// Enabled reports whether env is switched on.
+func (e DeveloperEnvironments) Enabled(env APIEnvironment) bool {
+	if env == APIEnvironmentSandbox {
+		return e.Sandbox
+	}
+	return e.Production
+}
+
+// WebhookDeliverySummary sums one endpoint's deliveries over a period.
+type WebhookDeliverySummary struct {
+	EndpointID uuid.UUID `json:"endpoint_id" db:"endpoint_id"`
+	URL        string    `json:"url" db:"url"`
+	Active     bool      `json:"active" db:"active"`
+	Total      int       `json:"total" db:"total"`
+	Succeeded  int       `json:"succeeded" db:"succeeded"`
+	Failed     int       `json:"failed" db:"failed"`
+	// Pending deliveries are queued or waiting for a retry.
+	Pending         int        `json:"pending" db:"pending"`
+	Retrying        int        `json:"retrying" db:"retrying"`
+	AvgDurationMS   int64      `json:"avg_duration_ms" db:"avg_duration_ms"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty" db:"last_delivered_at"`
+	LastFailedAt    *time.Time `json:"last_failed_at,omitempty" db:"last_failed_at"`
+	LastError       string     `json:"last_error,omitempty" db:"last_error"`
+}
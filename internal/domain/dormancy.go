@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// DormancyStatus is where a customer is in the dormancy workflow.
+type DormancyStatus string
+
+const (
+	// DormancyNotified: the customer has been warned that the account is
+	// about to become dormant.
+	DormancyNotified DormancyStatus = "notified"
+	// DormancyDormant: no activity for the dormancy period; the wallets are
+	// frozen until the customer reactivates.
+	DormancyDormant DormancyStatus = "dormant"
+	// DormancyEscheated: the balances were moved to the unclaimed balances
+	// account; the wallets stay frozen.
+	DormancyEscheated DormancyStatus = "escheated"
+	// DormancyReactivationRequested: an escheated customer came back and
+	// asked for the balances, which an admin must approve.
+	DormancyReactivationRequested DormancyStatus = "reactivation_requested"
+	// DormancyReactivated: the customer is back and the wallets are open.
+	DormancyReactivated DormancyStatus = "reactivated"
+)
+
+// DormancyCandidate is a customer without activity since LastActivityAt:
+// the latest of their last login, their wallets' last transaction and
+// their registration.
+type DormancyCandidate struct {
+	UserID         uuid.UUID `db:"user_id"`
+	CountryCode    string    `db:"country_code"`
+	LastActivityAt time.Time `db:"last_activity_at"`
+}
+
+// DormantAccount tracks one customer through the dormancy workflow.
+type DormantAccount struct {
+	UserID         uuid.UUID      `json:"user_id" db:"user_id"`
+	CountryCode    string         `json:"country_code" db:"country_code"`
+	Status         DormancyStatus `json:"status" db:"status"`
+	LastActivityAt time.Time      `json:"last_activity_at" db:"last_activity_at"`
+	// NoticesSent counts the warnings sent before dormancy for the current
+	// LastActivityAt.
+	NoticesSent         int        `json:"notices_sent" db:"notices_sent"`
+	LastNoticeAt        *time.Time `json:"last_notice_at,omitempty" db:"last_notice_at"`
+	DormantAt           *time.Time `json:"dormant_at,omitempty" db:"dormant_at"`
+	EscheatmentNoticeAt *time.Time `json:"escheatment_notice_at,omitempty" db:"escheatment_notice_at"`
+	EscheatedAt         *time.Time `json:"escheated_at,omitempty" db:"escheated_at"`
+	// FrozenWallets are the wallets frozen at dormancy; only these are
+	// reopened on reactivation, so a wallet an admin suspended stays so.
+	FrozenWallets pq.StringArray `json:"frozen_wallets" db:"frozen_wallets"`
+	ReactivatedAt *time.Time     `json:"reactivated_at,omitempty" db:"reactivated_at"`
+	ReactivatedBy *uuid.UUID     `json:"reactivated_by,omitempty" db:"reactivated_by"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// EscheatmentStatus is whether escheated money is still unclaimed.
+type EscheatmentStatus string
+
+const (
+	EscheatmentHeld     EscheatmentStatus = "held"
+	EscheatmentReturned EscheatmentStatus = "returned"
+)
+
+// Escheatment is one wallet balance moved to the unclaimed balances
+// account, and its return if the customer reclaimed it.
+type Escheatment struct {
+	ID                  uuid.UUID         `json:"id" db:"id"`
+	UserID              uuid.UUID         `json:"user_id" db:"user_id"`
+	WalletID            uuid.UUID         `json:"wallet_id" db:"wallet_id"`
+	CountryCode         string            `json:"country_code" db:"country_code"`
+	Amount              decimal.Decimal   `json:"amount" db:"amount"`
+	Currency            Currency          `json:"currency" db:"currency"`
+	Status              EscheatmentStatus `json:"status" db:"status"`
+	TransactionID       uuid.UUID         `json:"transaction_id" db:"transaction_id"`
+	ReturnTransactionID *uuid.UUID        `json:"return_transaction_id,omitempty" db:"return_transaction_id"`
+	EscheatedAt         time.Time         `json:"escheated_at" db:"escheated_at"`
+	ReturnedAt          *time.Time        `json:"returned_at,omitempty" db:"returned_at"`
+}
+
+// EscheatmentTotal sums the escheatments in one currency and country.
+type EscheatmentTotal struct {
+	CountryCode string          `json:"country_code" db:"country_code"`
+	Currency    Currency        `json:"currency" db:"currency"`
+	Count       int             `json:"count" db:"count"`
+	Escheated   decimal.Decimal `json:"escheated" db:"escheated"`
+	Returned    decimal.Decimal `json:"returned" db:"returned"`
+	Held        decimal.Decimal `json:"held" db:"held"`
+}
+
+// EscheatmentReport is the escheatments made in [From, To), for filing
+// with the regulator.
+type EscheatmentReport struct {
+	From   time.Time          `json:"from"`
+	To     time.Time          `json:"to"`
+	Totals []EscheatmentTotal `json:"totals"`
+	Items  []Escheatment      `json:"items"`
+}
+
+// DormancyRun counts what one dormancy pass did.
+type DormancyRun struct {
+	Examined           int `json:"examined"`
+	Notified           int `json:"notified"`
+	Dormant            int `json:"dormant"`
+	EscheatmentNotices int `json:"escheatment_notices"`
+	Escheated          int `json:"escheated"`
+	Failed             int `json:"failed"`
+}
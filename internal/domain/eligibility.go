@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// EligibilityRule is who may open an account in a country. Empty lists do
+// not restrict registration.
+type EligibilityRule struct {
+	CountryCode string `json:"country_code" db:"country_code"`
+	// MinAge is the minimum age in whole years; 0 disables the age check.
+	MinAge int `json:"min_age" db:"min_age"`
+	// RequireDateOfBirth makes a date of birth mandatory even without a
+	// minimum age, so it can be checked against the ID document at KYC.
+	RequireDateOfBirth      bool           `json:"require_date_of_birth" db:"require_date_of_birth"`
+	RestrictedNationalities pq.StringArray `json:"restricted_nationalities" db:"restricted_nationalities"`
+	RestrictedUserTypes     pq.StringArray `json:"restricted_user_types" db:"restricted_user_types"`
+	// RestrictedBusinessTypes applies to merchants and agents.
+	RestrictedBusinessTypes pq.StringArray `json:"restricted_business_types" db:"restricted_business_types"`
+	UpdatedBy               *uuid.UUID     `json:"updated_by,omitempty" db:"updated_by"`
+	UpdatedAt               time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// Eligibility rejection reason codes.
+const (
+	EligibilityDateOfBirthRequired    = "date_of_birth_required"
+	EligibilityDateOfBirthInvalid     = "date_of_birth_invalid"
+	EligibilityUnderage               = "underage"
+	EligibilityNationalityRequired    = "nationality_required"
+	EligibilityNationalityRestricted  = "nationality_restricted"
+	EligibilityUserTypeRestricted     = "user_type_restricted"
+	EligibilityBusinessTypeRequired   = "business_type_required"
+	EligibilityBusinessTypeRestricted = "business_type_restricted"
+)
+
+// EligibilityReason is one rule an applicant failed.
+type EligibilityReason struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// EligibilityReasons is stored as JSONB.
+type EligibilityReasons []EligibilityReason
+
+func (r EligibilityReasons) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *EligibilityReasons) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, r)
+}
+
+// EligibilityApplicant is what a registration declares about the applicant.
+type EligibilityApplicant struct {
+	CountryCode  string
+	UserType     UserType
+	DateOfBirth  *time.Time
+	Nationality  string
+	BusinessType string
+}
+
+// Check returns the reasons a is not eligible under r at now, or none.
+func (r *EligibilityRule) Check(a EligibilityApplicant, now time.Time) EligibilityReasons {
+	var reasons EligibilityReasons
+	fail := func(code, field, message string) {
+		reasons = append(reasons, EligibilityReason{Code: code, Field: field, Message: message})
+	}
+
+	if r.MinAge > 0 || r.RequireDateOfBirth {
+		switch {
+		case a.DateOfBirth == nil:
+			fail(EligibilityDateOfBirthRequired, "date_of_birth", "Date of birth is required to register in this country")
+		case a.DateOfBirth.After(now):
+			fail(EligibilityDateOfBirthInvalid, "date_of_birth", "Date of birth is in the future")
+		case AgeAt(*a.DateOfBirth, now) < r.MinAge:
+			fail(EligibilityUnderage, "date_of_birth", "Applicants must be at least the minimum age for this country")
+		}
+	}
+
+	if len(r.RestrictedNationalities) > 0 {
+		switch nationality := strings.ToUpper(a.Nationality); {
+		case nationality == "":
+			fail(EligibilityNationalityRequired, "nationality", "Nationality is required to register in this country")
+		case containsString(r.RestrictedNationalities, nationality):
+			fail(EligibilityNationalityRestricted, "nationality", "Accounts cannot be opened for this nationality")
+		}
+	}
+
+	if containsString(r.RestrictedUserTypes, string(a.UserType)) {
+		fail(EligibilityUserTypeRestricted, "user_type", "This account type is not available in this country")
+	}
+
+	if len(r.RestrictedBusinessTypes) > 0 && (a.UserType == UserTypeMerchant || a.UserType == UserTypeAgent) {
+		switch businessType := strings.ToLower(a.BusinessType); {
+		case businessType == "":
+			fail(EligibilityBusinessTypeRequired, "business_type", "Business type is required for business accounts in this country")
+		case containsString(r.RestrictedBusinessTypes, businessType):
+			fail(EligibilityBusinessTypeRestricted, "business_type", "Accounts cannot be opened for this type of business")
+		}
+	}
+	return reasons
+}
+
+// AgeAt is the age in whole years at now of someone born on dob.
+func AgeAt(dob, now time.Time) int {
+	y1, m1, d1 := dob.Date()
+	y2, m2, d2 := now.In(dob.Location()).Date()
+	age := y2 - y1
+	if m2 < m1 || (m2 == m1 && d2 < d1) {
+		age--
+	}
+	return age
+}
+
+// EligibilityRejectionStatus tracks a rejected registration through appeal.
+type EligibilityRejectionStatus string
+
+const (
+	EligibilityRejected EligibilityRejectionStatus = "rejected" // may be appealed
+	EligibilityAppealed EligibilityRejectionStatus = "appealed" // awaiting review
+	EligibilityApproved EligibilityRejectionStatus = "approved" // applicant may register
+	EligibilityDenied   EligibilityRejectionStatus = "denied"
+)
+
+// EligibilityRejection records a registration refused by the eligibility
+// rules, with what the applicant declared, and its appeal.
+type EligibilityRejection struct {
+	ID              uuid.UUID                  `json:"id" db:"id"`
+	Email           string                     `json:"email" db:"email"`
+	CountryCode     string                     `json:"country_code" db:"country_code"`
+	UserType        UserType                   `json:"user_type" db:"user_type"`
+	DateOfBirth     *time.Time                 `json:"date_of_birth,omitempty" db:"date_of_birth"`
+	Nationality     string                     `json:"nationality,omitempty" db:"nationality"`
+	BusinessType    string                     `json:"business_type,omitempty" db:"business_type"`
+	Reasons         EligibilityReasons         `json:"reasons" db:"reasons"`
+	Status          EligibilityRejectionStatus `json:"status" db:"status"`
+	AppealStatement *string                    `json:"appeal_statement,omitempty" db:"appeal_statement"`
+	AppealedAt      *time.Time                 `json:"appealed_at,omitempty" db:"appealed_at"`
+	ReviewedBy      *uuid.UUID                 `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewNote      *string                    `json:"review_note,omitempty" db:"review_note"`
+	ReviewedAt      *time.Time                 `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt       time.Time                  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type ExportKind string
+
+const (
+	ExportKindAuditLogs    ExportKind = "audit_logs"
+	ExportKindTransactions ExportKind = "transactions"
+	// Reports partners can generate for their own account.
+	ExportKindSettlementReport ExportKind = "settlement_report"
+	ExportKindFeeReport        ExportKind = "fee_report"
+)
+
+// IsReport reports whether k is a kind partners may generate themselves.
+func (k ExportKind) IsReport() bool {
+	return k == ExportKindSettlementReport || k == ExportKindFeeReport
+}
+
+type ExportStatus string
+
+const (
+	ExportStatusQueued    ExportStatus = "queued"
+	ExportStatusRunning   ExportStatus = "running"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+	ExportStatusExpired   ExportStatus = "expired"
+)
+
+// ExportFilters narrows the rows included in an export. Status and Currency
+// only apply to transaction exports.
+type ExportFilters struct {
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	UserID   *uuid.UUID `json:"user_id,omitempty"`
+	Status   string     `json:"status,omitempty"`
+	Currency string     `json:"currency,omitempty"`
+}
+
+func (f ExportFilters) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+func (f *ExportFilters) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, f)
+}
+
+// ExportJob is an asynchronous export of admin data to a downloadable file.
+// A job with an OwnerID is a partner's report and only ever covers that
+// owner's transactions, whatever its filters say.
+type ExportJob struct {
+	ID            uuid.UUID     `json:"id" db:"id"`
+	Kind          ExportKind    `json:"kind" db:"kind"`
+	OwnerID       *uuid.UUID    `json:"owner_id,omitempty" db:"owner_id"`
+	Filters       ExportFilters `json:"filters" db:"filters"`
+	Status        ExportStatus  `json:"status" db:"status"`
+	TotalRows     int           `json:"total_rows" db:"total_rows"`
+	ProcessedRows int           `json:"processed_rows" db:"processed_rows"`
+	FilePath      string        `json:"-" db:"file_path"`
+	FileSize      int64         `json:"file_size" db:"file_size"`
+	Error         *string       `json:"error,omitempty" db:"error"`
+	RequestedBy   uuid.UUID     `json:"requested_by" db:"requested_by"`
+	StartedAt     *time.Time    `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt   *time.Time    `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt     *time.Time    `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// Progress is the fraction of rows written, between 0 and 1.
+func (j *ExportJob) Progress() float64 {
+	if j.Status == ExportStatusCompleted {
+		return 1
+	}
+	if j.TotalRows <= 0 {
+		return 0
+	}
+	p := float64(j.ProcessedRows) / float64(j.TotalRows)
+	if p > 1 {
+		p = 1
+	}
+	return p
+}
+
+// ReportSchedule generates a partner report for each UTC day, the day after.
+// Currency, when set, limits the report to that currency.
+type ReportSchedule struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	OwnerID    uuid.UUID  `json:"owner_id" db:"owner_id"`
+	Kind       ExportKind `json:"kind" db:"kind"`
+	Currency   string     `json:"currency,omitempty" db:"currency"`
+	LastPeriod *time.Time `json:"last_period,omitempty" db:"last_period"`
+	CreatedBy  uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// SettlementReportRow is a completed transaction with the settlement batch
+// that carried it, if any.
+type SettlementReportRow struct {
+	ID                uuid.UUID       `db:"id"`
+	Reference         string          `db:"reference"`
+	SenderID          uuid.UUID       `db:"sender_id"`
+	ReceiverID        uuid.UUID       `db:"receiver_id"`
+	Amount            decimal.Decimal `db:"amount"`
+	Currency          Currency        `db:"currency"`
+	ConvertedAmount   decimal.Decimal `db:"converted_amount"`
+	ConvertedCurrency Currency        `db:"converted_currency"`
+	FeeAmount         decimal.Decimal `db:"fee_amount"`
+	FeeCurrency       Currency        `db:"fee_currency"`
+	NetAmount         decimal.Decimal `db:"net_amount"`
+	SettlementID      *uuid.UUID      `db:"settlement_id"`
+	SettlementBatch   string          `db:"settlement_batch"`
+	SettlementStatus  string          `db:"settlement_status"`
+	SettlementNetwork string          `db:"settlement_network"`
+	BlockchainTxHash  string          `db:"blockchain_tx_hash"`
+	CreatedAt         time.Time       `db:"created_at"`
+	CompletedAt       *time.Time      `db:"completed_at"`
+}
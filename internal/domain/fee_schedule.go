@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// FeeSchedule prices payments matching its criteria while it is in effect:
+// a flat fee plus a percentage of the amount, held between the minimum and
+// maximum when set. Nil criteria match anything. Amounts are in the source
+// currency, which schedules with any of them must name.
+type FeeSchedule struct {
+	ID                  uuid.UUID        `json:"id" db:"id"`
+	Name                string           `json:"name" db:"name"`
+	SourceCurrency      *Currency        `json:"source_currency,omitempty" db:"source_currency"`
+	DestinationCurrency *Currency        `json:"destination_currency,omitempty" db:"destination_currency"`
+	Channel             *string          `json:"channel,omitempty" db:"channel"`
+	UserType            *UserType        `json:"user_type,omitempty" db:"user_type"`
+	KYCLevel            *int             `json:"kyc_level,omitempty" db:"kyc_level"`
+	FlatFee             decimal.Decimal  `json:"flat_fee" db:"flat_fee"`
+	Percentage          decimal.Decimal  `json:"percentage" db:"percentage"`
+	MinFee              *decimal.Decimal `json:"min_fee,omitempty" db:"min_fee"`
+	MaxFee              *decimal.Decimal `json:"max_fee,omitempty" db:"max_fee"`
+	EffectiveFrom       time.Time        `json:"effective_from" db:"effective_from"`
+	EffectiveTo         *time.Time       `json:"effective_to,omitempty" db:"effective_to"`
+	CreatedBy           *uuid.UUID       `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt           time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// FeeQuery describes a payment to price.
+type FeeQuery struct {
+	SourceCurrency      Currency
+	DestinationCurrency Currency
+	Channel             string
+	UserType            UserType
+	KYCLevel            int
+	At                  time.Time
+}
+
+// InEffect reports whether the schedule applies to payments made at t.
+func (f *FeeSchedule) InEffect(t time.Time) bool {
+	return !t.Before(f.EffectiveFrom) && (f.EffectiveTo == nil || t.Before(*f.EffectiveTo))
+}
+
+// Matches reports whether the schedule prices q.
+func (f *FeeSchedule) Matches(q FeeQuery) bool {
+	return f.InEffect(q.At) &&
+		(f.SourceCurrency == nil || *f.SourceCurrency == q.SourceCurrency) &&
+		(f.DestinationCurrency == nil || *f.DestinationCurrency == q.DestinationCurrency) &&
+		(f.Channel == nil || strings.EqualFold(*f.Channel, q.Channel)) &&
+		(f.UserType == nil || *f.UserType == q.UserType) &&
+		(f.KYCLevel == nil || *f.KYCLevel == q.KYCLevel)
+}
+
+// specificity counts the criteria the schedule sets.
+func (f *FeeSchedule) specificity() int {
+	n := 0
+	for _, set := range []bool{f.SourceCurrency != nil, f.DestinationCurrency != nil, f.Channel != nil, f.UserType != nil, f.KYCLevel != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// SelectFeeSchedule returns the schedule that prices q, or nil when none
+// matches. The one setting the most criteria wins; among equally specific
+// ones the latest to take effect does, so a new schedule supersedes an
+// older one for the same payments from its start.
+func SelectFeeSchedule(schedules []FeeSchedule, q FeeQuery) *FeeSchedule {
+	var best *FeeSchedule
+	for i := range schedules {
+		f := &schedules[i]
+		if !f.Matches(q) {
+			continue
+		}
+		if best == nil || f.specificity() > best.specificity() ||
+			(f.specificity() == best.specificity() && (f.EffectiveFrom.After(best.EffectiveFrom) ||
+				(f.EffectiveFrom.Equal(best.EffectiveFrom) && f.CreatedAt.After(best.CreatedAt)))) {
+			best = f
+		}
+	}
+	return best
+}
+
+// FeeBreakdown shows how a payment's fee is made up.
+type FeeBreakdown struct {
+	// ScheduleID is the schedule that priced the payment; payments no
+	// schedule matches are charged the sender's plan or segment rate.
+	ScheduleID    *uuid.UUID       `json:"schedule_id,omitempty"`
+	ScheduleName  string           `json:"schedule_name,omitempty"`
+	Amount        decimal.Decimal  `json:"amount"`
+	Currency      Currency         `json:"currency"`
+	FlatFee       decimal.Decimal  `json:"flat_fee"`
+	Percentage    decimal.Decimal  `json:"percentage"`
+	PercentageFee decimal.Decimal  `json:"percentage_fee"`
+	MinFee        *decimal.Decimal `json:"min_fee,omitempty"`
+	MaxFee        *decimal.Decimal `json:"max_fee,omitempty"`
+	// Capped is "min" or "max" when a bound set the fee.
+	Capped     string          `json:"capped,omitempty"`
+	Fee        decimal.Decimal `json:"fee"`
+	TotalDebit decimal.Decimal `json:"total_debit"`
+}
+
+// Breakdown prices amount under the schedule, to the cent.
+func (f *FeeSchedule) Breakdown(amount decimal.Decimal, currency Currency) *FeeBreakdown {
+	id := f.ID
+	b := &FeeBreakdown{
+		ScheduleID:    &id,
+		ScheduleName:  f.Name,
+		Amount:        amount,
+		Currency:      currency,
+		FlatFee:       f.FlatFee,
+		Percentage:    f.Percentage,
+		PercentageFee: amount.Mul(f.Percentage).Round(2),
+		MinFee:        f.MinFee,
+		MaxFee:        f.MaxFee,
+	}
+	b.Fee = b.FlatFee.Add(b.PercentageFee)
+	if f.MinFee != nil && b.Fee.LessThan(*f.MinFee) {
+		b.Fee, b.Capped = *f.MinFee, "min"
+	}
+	if f.MaxFee != nil && b.Fee.GreaterThan(*f.MaxFee) {
+		b.Fee, b.Capped = *f.MaxFee, "max"
+	}
+	b.TotalDebit = amount.Add(b.Fee)
+	return b
+}
+
+// RateFeeBreakdown prices amount at a plain rate, as payments no schedule
+// matches are.
+func RateFeeBreakdown(amount decimal.Decimal, currency Currency, rate decimal.Decimal) *FeeBreakdown {
+	fee := amount.Mul(rate)
+	return &FeeBreakdown{
+		Amount:        amount,
+		Currency:      currency,
+		FlatFee:       decimal.Zero,
+		Percentage:    rate,
+		PercentageFee: fee,
+		Fee:           fee,
+		TotalDebit:    amount.Add(fee),
+	}
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FreezeScope is the kind of money movement a freeze pauses.
+type FreezeScope string
+
+const (
+	// FreezeAll pauses every payment, withdrawal and settlement.
+	FreezeAll FreezeScope = "all"
+	// FreezeCrossBorder pauses payments and settlements that convert
+	// between currencies.
+	FreezeCrossBorder FreezeScope = "cross_border"
+	// FreezeCorridor pauses one corridor, such as MWK-ZAR.
+	FreezeCorridor FreezeScope = "corridor"
+	// FreezeWithdrawals pauses cash-outs to banks and mobile money.
+	FreezeWithdrawals FreezeScope = "withdrawals"
+)
+
+// FreezeStatus is where a freeze is in its maker-checker workflow.
+type FreezeStatus string
+
+const (
+	// FreezePending: proposed by one admin, waiting for another to approve.
+	FreezePending FreezeStatus = "pending_approval"
+	// FreezeActive: approved and enforced. A lift may be pending.
+	FreezeActive FreezeStatus = "active"
+	// FreezeRejected: the proposal was turned down; it never took effect.
+	FreezeRejected FreezeStatus = "rejected"
+	// FreezeLifted: a second admin approved lifting the freeze.
+	FreezeLifted FreezeStatus = "lifted"
+)
+
+// MovementKind is the kind of money movement checked against freezes.
+type MovementKind string
+
+const (
+	MovementPayment    MovementKind = "payment"
+	MovementWithdrawal MovementKind = "withdrawal"
+	MovementSettlement MovementKind = "settlement"
+)
+
+// Movement is one money movement about to start: from and to are the
+// currencies on each side, equal when nothing is converted.
+type Movement struct {
+	Kind MovementKind
+	From Currency
+	To   Currency
+}
+
+// MovementFreeze is an emergency pause of a kind of money movement.
+type MovementFreeze struct {
+	ID    uuid.UUID   `json:"id" db:"id"`
+	Scope FreezeScope `json:"scope" db:"scope"`
+	// Corridor is set for corridor freezes, as FROM-TO currencies.
+	Corridor string       `json:"corridor,omitempty" db:"corridor"`
+	Status   FreezeStatus `json:"status" db:"status"`
+	// Reason is for admins; Message is shown to customers and on the
+	// status page.
+	Reason          string     `json:"reason" db:"reason"`
+	Message         string     `json:"message" db:"message"`
+	RequestedBy     uuid.UUID  `json:"requested_by" db:"requested_by"`
+	RequestedAt     time.Time  `json:"requested_at" db:"requested_at"`
+	ApprovedBy      *uuid.UUID `json:"approved_by,omitempty" db:"approved_by"`
+	ActivatedAt     *time.Time `json:"activated_at,omitempty" db:"activated_at"`
+	RejectedBy      *uuid.UUID `json:"rejected_by,omitempty" db:"rejected_by"`
+	LiftRequestedBy *uuid.UUID `json:"lift_requested_by,omitempty" db:"lift_requested_by"`
+	LiftRequestedAt *time.Time `json:"lift_requested_at,omitempty" db:"lift_requested_at"`
+	LiftedBy        *uuid.UUID `json:"lifted_by,omitempty" db:"lifted_by"`
+	LiftedAt        *time.Time `json:"lifted_at,omitempty" db:"lifted_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Covers reports whether the freeze pauses m.
+func (f *MovementFreeze) Covers(m Movement) bool {
+	switch f.Scope {
+	case FreezeAll:
+		return true
+	case FreezeCrossBorder:
+		return m.Kind != MovementWithdrawal && m.From != m.To
+	case FreezeCorridor:
+		return m.Kind != MovementWithdrawal && f.Corridor == string(m.From)+"-"+string(m.To)
+	case FreezeWithdrawals:
+		return m.Kind == MovementWithdrawal
+	}
+	return false
+}
+
+// FreezeAction is a step recorded in a freeze's audit trail.
+type FreezeAction string
+
+const (
+	FreezeActionRequested     FreezeAction = "requested"
+	FreezeActionApproved      FreezeAction = "approved"
+	FreezeActionRejected      FreezeAction = "rejected"
+	FreezeActionLiftRequested FreezeAction = "lift_requested"
+	FreezeActionLifted        FreezeAction = "lifted"
+	FreezeActionLiftRejected  FreezeAction = "lift_rejected"
+)
+
+// FreezeEvent is one entry in a freeze's audit trail.
+type FreezeEvent struct {
+	ID        uuid.UUID    `json:"id" db:"id"`
+	FreezeID  uuid.UUID    `json:"freeze_id" db:"freeze_id"`
+	Action    FreezeAction `json:"action" db:"action"`
+	ActorID   uuid.UUID    `json:"actor_id" db:"actor_id"`
+	Note      string       `json:"note,omitempty" db:"note"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+// FundingMethod is how a customer pays money into a wallet from outside
+// the platform.
+type FundingMethod string
+
+const (
+	FundingMobileMoney  FundingMethod = "mobile_money"
+	FundingBankTransfer FundingMethod = "bank_transfer"
+	FundingCard         FundingMethod = "card"
+)
+
+func (m FundingMethod) Valid() bool {
+	switch m {
+	case FundingMobileMoney, FundingBankTransfer, FundingCard:
+		return true
+	}
+	return false
+}
+
+// PayoutDestinationType is where a withdrawal is paid out to.
+type PayoutDestinationType string
+
+const (
+	PayoutBankAccount PayoutDestinationType = "bank_account"
+	PayoutMobileMoney PayoutDestinationType = "mobile_money"
+)
+
+// PayoutDestination is the account outside the platform a withdrawal is
+// paid to: a bank account (BankCode and AccountNumber) or a mobile money
+// wallet (Phone).
+type PayoutDestination struct {
+	Type          PayoutDestinationType `json:"type"`
+	AccountName   string                `json:"account_name"`
+	BankCode      string                `json:"bank_code,omitempty"`
+	AccountNumber string                `json:"account_number,omitempty"`
+	Phone         string                `json:"phone,omitempty"`
+}
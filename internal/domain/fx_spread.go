@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// FXSpreadUnit is how an FX spread is expressed.
+type FXSpreadUnit string
+
+const (
+	// FXSpreadPercent is a percentage of the mid rate.
+	FXSpreadPercent FXSpreadUnit = "percent"
+	// FXSpreadPips is a number of pips of PipSize.
+	FXSpreadPips FXSpreadUnit = "pips"
+)
+
+// DefaultPipSize is the pip of a spread set in pips without one.
+var DefaultPipSize = decimal.RequireFromString("0.0001")
+
+// FXSpread is the margin the platform takes on a currency pair: the rate
+// from BaseCurrency to TargetCurrency is sold at the mid rate less the
+// margin and bought at the mid rate plus it.
+type FXSpread struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	BaseCurrency   Currency        `json:"base_currency" db:"base_currency"`
+	TargetCurrency Currency        `json:"target_currency" db:"target_currency"`
+	Unit           FXSpreadUnit    `json:"unit" db:"unit"`
+	Value          decimal.Decimal `json:"value" db:"value"`
+	// PipSize is set for spreads in pips.
+	PipSize   *decimal.Decimal `json:"pip_size,omitempty" db:"pip_size"`
+	Note      string           `json:"note,omitempty" db:"note"`
+	UpdatedBy uuid.UUID        `json:"updated_by" db:"updated_by"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// Margin is how far from mid the customer rates are, in rate units.
+func (s *FXSpread) Margin(mid decimal.Decimal) decimal.Decimal {
+	if s.Unit == FXSpreadPips {
+		pip := DefaultPipSize
+		if s.PipSize != nil {
+			pip = *s.PipSize
+		}
+		return s.Value.Mul(pip)
+	}
+	return mid.Mul(s.Value).Div(decimal.NewFromInt(100))
+}
+
+// FXRevenue is what spreads earned on one corridor on one UTC day: the
+// difference between what receivers would have got at the mid rate and
+// what they got, as the ledger books it as FX gain.
+type FXRevenue struct {
+	Day            time.Time       `json:"day" db:"day"`
+	BaseCurrency   Currency        `json:"base_currency" db:"base_currency"`
+	TargetCurrency Currency        `json:"target_currency" db:"target_currency"`
+	Payments       int             `json:"payments" db:"payments"`
+	Volume         decimal.Decimal `json:"volume" db:"volume"`
+	Converted      decimal.Decimal `json:"converted" db:"converted"`
+	// Revenue is in TargetCurrency, and BaseRevenue the same at the mid
+	// rates the payments were priced against.
+	Revenue     decimal.Decimal `json:"revenue" db:"revenue"`
+	BaseRevenue decimal.Decimal `json:"base_revenue" db:"base_revenue"`
+}
+
+// FXRevenueFilter selects the payments of an FX revenue report: those made
+// in [From, To), on one corridor when both currencies are set.
+type FXRevenueFilter struct {
+	From           time.Time
+	To             time.Time
+	BaseCurrency   Currency
+	TargetCurrency Currency
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// WorkerStatus is how a background worker's heartbeat looks to an observer.
+type WorkerStatus string
+
+const (
+	WorkerHealthy WorkerStatus = "healthy"
+	// WorkerLate workers missed a beat but not yet enough to be stalled.
+	WorkerLate WorkerStatus = "late"
+	// WorkerStalled workers missed enough beats to be alerted on.
+	WorkerStalled WorkerStatus = "stalled"
+	// WorkerStopped workers were stopped by their process shutting down.
+	WorkerStopped WorkerStatus = "stopped"
+)
+
+// WorkerHeartbeat is the last beat one process recorded for one of its
+// background workers.
+type WorkerHeartbeat struct {
+	Service  string `json:"service" db:"service"`
+	Worker   string `json:"worker" db:"worker"`
+	Instance string `json:"instance" db:"instance"`
+	// IntervalSeconds is how often the worker is expected to beat.
+	IntervalSeconds int `json:"interval_seconds" db:"interval_seconds"`
+	// Restartable workers are restarted in-process once they stall.
+	Restartable bool       `json:"restartable" db:"restartable"`
+	Restarts    int        `json:"restarts" db:"restarts"`
+	StartedAt   time.Time  `json:"started_at" db:"started_at"`
+	LastBeatAt  time.Time  `json:"last_beat_at" db:"last_beat_at"`
+	StoppedAt   *time.Time `json:"stopped_at,omitempty" db:"stopped_at"`
+	AlertedAt   *time.Time `json:"alerted_at,omitempty" db:"alerted_at"`
+	// AlertedRestarts is how many of the restarts admins were alerted to.
+	AlertedRestarts int       `json:"-" db:"alerted_restarts"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+
+	Status WorkerStatus `json:"status" db:"-"`
+}
+
+// Interval is how often the worker is expected to beat.
+func (h WorkerHeartbeat) Interval() time.Duration {
+	return time.Duration(h.IntervalSeconds) * time.Second
+}
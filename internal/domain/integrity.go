@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// IntegrityCheck names one of the scheduled database integrity checks.
+type IntegrityCheck string
+
+const (
+	// IntegrityTransactionWithoutLedger: a completed transaction that moved
+	// money has no ledger entries.
+	IntegrityTransactionWithoutLedger IntegrityCheck = "transaction_without_ledger"
+	// IntegrityDocumentWithoutFile: a KYC document's file is missing from
+	// storage.
+	IntegrityDocumentWithoutFile IntegrityCheck = "document_without_file"
+	// IntegrityWalletWithoutUser: an open wallet whose owner is missing or
+	// deleted.
+	IntegrityWalletWithoutUser IntegrityCheck = "wallet_without_user"
+	// IntegrityDanglingReference: a reference the database cannot enforce,
+	// typically across schemas, points at a row that does not exist.
+	IntegrityDanglingReference IntegrityCheck = "dangling_reference"
+	// IntegrityStalePending: a transaction, settlement or document has
+	// sat in an in-flight state for too long.
+	IntegrityStalePending IntegrityCheck = "stale_pending"
+)
+
+// IntegrityChecks are all checks, in the order they run.
+var IntegrityChecks = []IntegrityCheck{
+	IntegrityTransactionWithoutLedger,
+	IntegrityDocumentWithoutFile,
+	IntegrityWalletWithoutUser,
+	IntegrityDanglingReference,
+	IntegrityStalePending,
+}
+
+// IntegrityFindingStatus is whether a finding still holds.
+type IntegrityFindingStatus string
+
+const (
+	IntegrityFindingOpen IntegrityFindingStatus = "open"
+	// IntegrityFindingResolved findings were not seen again by a later run
+	// of their check.
+	IntegrityFindingResolved IntegrityFindingStatus = "resolved"
+)
+
+// IntegrityFinding is one problem an integrity check found. A problem seen
+// by successive runs stays one open finding; it is resolved by the first
+// run of its check that no longer sees it.
+type IntegrityFinding struct {
+	ID           uuid.UUID              `json:"id" db:"id"`
+	Check        IntegrityCheck         `json:"check" db:"check_name"`
+	Severity     string                 `json:"severity" db:"severity"`
+	ResourceType string                 `json:"resource_type" db:"resource_type"`
+	ResourceID   string                 `json:"resource_id" db:"resource_id"`
+	Detail       string                 `json:"detail" db:"detail"`
+	Status       IntegrityFindingStatus `json:"status" db:"status"`
+	FirstSeenAt  time.Time              `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt   time.Time              `json:"last_seen_at" db:"last_seen_at"`
+	ResolvedAt   *time.Time             `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// IntegrityRun is one pass of the integrity checks. Checks that failed to
+// run are listed in Failed and leave their findings as they were.
+type IntegrityRun struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	StartedAt  time.Time      `json:"started_at" db:"started_at"`
+	FinishedAt time.Time      `json:"finished_at" db:"finished_at"`
+	Open       int            `json:"open" db:"open_findings"`
+	Opened     int            `json:"opened" db:"opened"`
+	Resolved   int            `json:"resolved" db:"resolved"`
+	Failed     pq.StringArray `json:"failed" db:"failed"`
+}
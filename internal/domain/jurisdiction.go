@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JurisdictionScope is the direction of money a restriction stops.
+type JurisdictionScope string
+
+const (
+	// JurisdictionInbound stops money coming from the country.
+	JurisdictionInbound JurisdictionScope = "inbound"
+	// JurisdictionOutbound stops money going to the country.
+	JurisdictionOutbound JurisdictionScope = "outbound"
+	// JurisdictionBoth stops money either way.
+	JurisdictionBoth JurisdictionScope = "both"
+)
+
+// RestrictedJurisdiction is an embargo or blocked-country entry: money may
+// not move from and/or to CountryCode from EffectiveFrom until
+// EffectiveUntil, or indefinitely when that is nil.
+type RestrictedJurisdiction struct {
+	ID          uuid.UUID         `json:"id" db:"id"`
+	CountryCode string            `json:"country_code" db:"country_code"` // ISO 3166-1 alpha-2
+	Scope       JurisdictionScope `json:"scope" db:"scope"`
+	// Reason says why the country is restricted; Authority is the sanction
+	// programme, regulation or directive it implements.
+	Reason         string     `json:"reason" db:"reason"`
+	Authority      string     `json:"authority,omitempty" db:"authority"`
+	EffectiveFrom  time.Time  `json:"effective_from" db:"effective_from"`
+	EffectiveUntil *time.Time `json:"effective_until,omitempty" db:"effective_until"`
+	CreatedBy      uuid.UUID  `json:"created_by" db:"created_by"`
+	UpdatedBy      uuid.UUID  `json:"updated_by" db:"updated_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// InForce reports whether the restriction applies at t.
+func (j *RestrictedJurisdiction) InForce(t time.Time) bool {
+	return !t.Before(j.EffectiveFrom) && (j.EffectiveUntil == nil || t.Before(*j.EffectiveUntil))
+}
+
+// Status is "scheduled", "in_force" or "ended" at t.
+func (j *RestrictedJurisdiction) Status(t time.Time) string {
+	switch {
+	case t.Before(j.EffectiveFrom):
+		return "scheduled"
+	case j.InForce(t):
+		return "in_force"
+	}
+	return "ended"
+}
+
+// Stops reports whether the restriction stops money moving in direction,
+// JurisdictionInbound or JurisdictionOutbound.
+func (j *RestrictedJurisdiction) Stops(direction JurisdictionScope) bool {
+	return j.Scope == JurisdictionBoth || j.Scope == direction
+}
+
+func (j RestrictedJurisdiction) Value() (driver.Value, error) {
+	return json.Marshal(j)
+}
+
+func (j *RestrictedJurisdiction) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, j)
+}
+
+type JurisdictionAction string
+
+const (
+	JurisdictionCreated JurisdictionAction = "created"
+	JurisdictionUpdated JurisdictionAction = "updated"
+	JurisdictionDeleted JurisdictionAction = "deleted"
+)
+
+// JurisdictionChange is an entry in the append-only log of changes to the
+// restricted jurisdictions list, kept for regulator review. Before is nil
+// for additions and After for deletions.
+type JurisdictionChange struct {
+	ID             uuid.UUID               `json:"id" db:"id"`
+	JurisdictionID uuid.UUID               `json:"jurisdiction_id" db:"jurisdiction_id"`
+	CountryCode    string                  `json:"country_code" db:"country_code"`
+	Action         JurisdictionAction      `json:"action" db:"action"`
+	Before         *RestrictedJurisdiction `json:"before,omitempty" db:"before"`
+	After          *RestrictedJurisdiction `json:"after,omitempty" db:"after"`
+	Note           string                  `json:"note" db:"note"`
+	ChangedBy      uuid.UUID               `json:"changed_by" db:"changed_by"`
+	ChangedAt      time.Time               `json:"changed_at" db:"changed_at"`
+}
+
+// JurisdictionChangeFilter narrows the change log; zero fields match all.
+type JurisdictionChangeFilter struct {
+	CountryCode string
+	From        time.Time
+	To          time.Time
+}
+
+// JurisdictionFlow is the countries money leaves and reaches in one
+// movement. Empty entries are ignored.
+type JurisdictionFlow struct {
+	Origins      []string
+	Destinations []string
+}
+
+// CorridorFlow is the flow of a movement between two currencies: from the
+// country issuing the first to the one issuing the second. Currencies
+// shared by several countries, such as the euro, name none.
+func CorridorFlow(from, to Currency) JurisdictionFlow {
+	return JurisdictionFlow{Origins: []string{CurrencyCountry(from)}, Destinations: []string{CurrencyCountry(to)}}
+}
+
+// currencyCountries maps national currencies to their issuing country.
+var currencyCountries = map[Currency]string{
+	"MWK": "MW", "CNY": "CN", "ZMW": "ZM", "USD": "US",
+	"ZAR": "ZA", "KES": "KE", "NGN": "NG", "GHS": "GH", "UGX": "UG", "TZS": "TZ", "RWF": "RW",
+	"INR": "IN", "JPY": "JP", "KRW": "KR", "SGD": "SG", "HKD": "HK",
+	"GBP": "GB", "CHF": "CH",
+}
+
+// CurrencyCountry returns the country issuing c, or "" when it is not a
+// single country's currency.
+func CurrencyCountry(c Currency) string {
+	return currencyCountries[c]
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KYCDecision is what a reviewer decided about a KYC profile.
+type KYCDecision string
+
+const (
+	KYCDecisionApprove KYCDecision = "approve"
+	KYCDecisionReject  KYCDecision = "reject"
+	// KYCDecisionRequestInfo sends the profile's open documents back to
+	// the user to upload again.
+	KYCDecisionRequestInfo KYCDecision = "request_info"
+)
+
+// KYCReview is one reviewer decision on a user's KYC profile. Every
+// decision is kept, so a profile's review history survives re-uploads.
+type KYCReview struct {
+	ID         uuid.UUID   `json:"id" db:"id"`
+	UserID     uuid.UUID   `json:"user_id" db:"user_id"`
+	ReviewerID uuid.UUID   `json:"reviewer_id" db:"reviewer_id"`
+	Decision   KYCDecision `json:"decision" db:"decision"`
+	Notes      *string     `json:"notes,omitempty" db:"notes"`
+	ReviewedAt time.Time   `json:"reviewed_at" db:"reviewed_at"`
+}
+
+// KYCQueueEntry is a user whose KYC profile waits in a review queue.
+type KYCQueueEntry struct {
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	KYCStatus KYCStatus `json:"kyc_status" db:"kyc_status"`
+	// Documents counts the profile's current documents.
+	Documents int `json:"documents" db:"documents"`
+	// WaitingSince is when the oldest current document was uploaded.
+	WaitingSince time.Time `json:"waiting_since" db:"waiting_since"`
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// LedgerAccountType is the class of an account in the chart of accounts.
+type LedgerAccountType string
+
+const (
+	LedgerAccountAsset     LedgerAccountType = "asset"
+	LedgerAccountLiability LedgerAccountType = "liability"
+	LedgerAccountEquity    LedgerAccountType = "equity"
+	LedgerAccountIncome    LedgerAccountType = "income"
+	LedgerAccountExpense   LedgerAccountType = "expense"
+)
+
+// DebitNormal reports whether accounts of type t grow with debits.
+func (t LedgerAccountType) DebitNormal() bool {
+	return t == LedgerAccountAsset || t == LedgerAccountExpense
+}
+
+// Codes of the accounts in the chart, as seeded by migrations 040, 047 and
+// 057.
+const (
+	// AccountSettlementClearing holds cross-currency payments between the
+	// sender's currency leaving and the receiver's arriving.
+	AccountSettlementClearing = "1010"
+	// AccountProviderClearing is money held at top-up and payout providers.
+	AccountProviderClearing = "1020"
+	// AccountCreditInterestReceivable is interest accrued on overdrawn
+	// wallets that has not been collected yet.
+	AccountCreditInterestReceivable = "1030"
+	// AccountCustomerWallets is what the platform owes wallet holders.
+	AccountCustomerWallets = "2010"
+	// AccountUnclaimedBalances is what the platform owes the holders of
+	// dormant wallets whose balances were escheated.
+	AccountUnclaimedBalances = "2020"
+	// AccountOpeningBalances offsets the wallet balances brought forward
+	// when the general ledger started.
+	AccountOpeningBalances = "3010"
+	// AccountFeeIncome is fees charged, including what the fee collector's
+	// wallets hold.
+	AccountFeeIncome = "4010"
+	// AccountFXGainLoss is the spread between the mid rate and the rate a
+	// customer converted at.
+	AccountFXGainLoss = "4020"
+	// AccountCreditInterestIncome is interest charged on overdrawn wallets.
+	AccountCreditInterestIncome = "4030"
+)
+
+// LedgerAccount is an account in the chart of accounts.
+type LedgerAccount struct {
+	Code        string            `json:"code" db:"code"`
+	Name        string            `json:"name" db:"name"`
+	Type        LedgerAccountType `json:"type" db:"account_type"`
+	Description string            `json:"description" db:"description"`
+}
+
+// ChartOfAccounts is every account a journal may post to.
+var ChartOfAccounts = []LedgerAccount{
+	{AccountSettlementClearing, "settlement_clearing", LedgerAccountAsset, "Cross-currency payments between leaving one currency and arriving in another"},
+	{AccountProviderClearing, "provider_clearing", LedgerAccountAsset, "Money held at top-up and payout providers"},
+	{AccountCreditInterestReceivable, "credit_interest_receivable", LedgerAccountAsset, "Interest accrued on overdrawn wallets and not yet collected"},
+	{AccountCustomerWallets, "customer_wallets", LedgerAccountLiability, "What the platform owes wallet holders"},
+	{AccountUnclaimedBalances, "unclaimed_balances", LedgerAccountLiability, "Escheated balances of dormant wallets, held for their owners or the regulator"},
+	{AccountOpeningBalances, "opening_balances", LedgerAccountEquity, "Wallet balances brought forward when the general ledger started"},
+	{AccountFeeIncome, "fee_income", LedgerAccountIncome, "Fees charged, including what the fee collector's wallets hold"},
+	{AccountFXGainLoss, "fx_gain_loss", LedgerAccountIncome, "Spread between the mid rate and the rate customers converted at"},
+	{AccountCreditInterestIncome, "credit_interest_income", LedgerAccountIncome, "Interest charged on overdrawn wallets"},
+}
+
+// LookupLedgerAccount returns the account with code, if it is in the chart.
+func LookupLedgerAccount(code string) (LedgerAccount, bool) {
+	for _, a := range ChartOfAccounts {
+		if a.Code == code {
+			return a, true
+		}
+	}
+	return LedgerAccount{}, false
+}
+
+// TrialBalanceLine is an account's totals in one currency.
+type TrialBalanceLine struct {
+	AccountCode string            `json:"account_code" db:"account_code"`
+	AccountName string            `json:"account_name" db:"account_name"`
+	AccountType LedgerAccountType `json:"account_type" db:"account_type"`
+	Currency    Currency          `json:"currency" db:"currency"`
+	Debit       decimal.Decimal   `json:"debit" db:"debit"`
+	Credit      decimal.Decimal   `json:"credit" db:"credit"`
+	// Balance is signed by the account's normal side: a liability with
+	// more credits than debits has a positive balance.
+	Balance decimal.Decimal `json:"balance"`
+}
+
+// TrialBalanceTotal is the sum of every account in one currency. The books
+// balance when debits equal credits.
+type TrialBalanceTotal struct {
+	Currency Currency        `json:"currency"`
+	Debit    decimal.Decimal `json:"debit"`
+	Credit   decimal.Decimal `json:"credit"`
+	Balanced bool            `json:"balanced"`
+}
+
+// TrialBalance is every account's totals as of a point in time.
+type TrialBalance struct {
+	AsOf     time.Time           `json:"as_of"`
+	Lines    []TrialBalanceLine  `json:"lines"`
+	Totals   []TrialBalanceTotal `json:"totals"`
+	Balanced bool                `json:"balanced"`
+}
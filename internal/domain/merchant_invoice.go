@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type MerchantInvoiceStatus string
+
+const (
+	// MerchantInvoiceDraft invoices are only visible to the merchant.
+	MerchantInvoiceDraft MerchantInvoiceStatus = "draft"
+	// MerchantInvoiceSent invoices can be paid through their link until
+	// they expire.
+	MerchantInvoiceSent      MerchantInvoiceStatus = "sent"
+	MerchantInvoicePaid      MerchantInvoiceStatus = "paid"
+	MerchantInvoiceExpired   MerchantInvoiceStatus = "expired"
+	MerchantInvoiceCancelled MerchantInvoiceStatus = "cancelled"
+)
+
+// MerchantInvoice is a merchant's request to be paid, unlike Invoice, which
+// bills an account for the platform's own fees. Once sent, anyone with its
+// link can pay it, or only PayerID when the merchant addressed it to
+// someone; the money goes to the merchant's wallet in its currency.
+type MerchantInvoice struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	MerchantID uuid.UUID  `json:"merchant_id" db:"merchant_id"`
+	WalletID   uuid.UUID  `json:"wallet_id" db:"wallet_id"`
+	PayerID    *uuid.UUID `json:"payer_id,omitempty" db:"payer_id"`
+	// Reference is the merchant's own invoice number, unique among their
+	// invoices.
+	Reference string                `json:"reference,omitempty" db:"reference"`
+	Amount    decimal.Decimal       `json:"amount" db:"amount"`
+	Currency  Currency              `json:"currency" db:"currency"`
+	Memo      string                `json:"memo,omitempty" db:"memo"`
+	Status    MerchantInvoiceStatus `json:"status" db:"status"`
+	// Token identifies the invoice in its payment link.
+	Token         string     `json:"-" db:"token"`
+	ExpiresAt     time.Time  `json:"expires_at" db:"expires_at"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty" db:"transaction_id"`
+	PaidBy        *uuid.UUID `json:"paid_by,omitempty" db:"paid_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+	PaidAt        *time.Time `json:"paid_at,omitempty" db:"paid_at"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// IsPayable reports whether the invoice can be paid at now.
+func (i *MerchantInvoice) IsPayable(now time.Time) bool {
+	return i.Status == MerchantInvoiceSent && now.Before(i.ExpiresAt)
+}
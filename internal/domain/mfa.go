@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFAMethod is a way of proving the second factor at login.
+type MFAMethod string
+
+const (
+	MFAMethodTOTP       MFAMethod = "totp"
+	MFAMethodSMS        MFAMethod = "sms"
+	MFAMethodEmail      MFAMethod = "email"
+	MFAMethodBackupCode MFAMethod = "backup_code"
+)
+
+// MFAPolicy says which accounts must use a second factor at login even when
+// they have not enrolled TOTP. Those accounts fall back to SMS or email
+// codes.
+type MFAPolicy struct {
+	RequiredForAdmins bool `json:"required_for_admins" db:"required_for_admins"`
+	// RequiredKYCLevel forces MFA on accounts at this KYC level or above;
+	// zero disables it.
+	RequiredKYCLevel int        `json:"required_kyc_level" db:"required_kyc_level"`
+	UpdatedBy        *uuid.UUID `json:"updated_by,omitempty" db:"updated_by"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Requires reports whether the policy forces a second factor on u.
+func (p *MFAPolicy) Requires(u *User) bool {
+	if p.RequiredForAdmins && u.UserType == UserTypeAdmin {
+		return true
+	}
+	return p.RequiredKYCLevel > 0 && u.KYCLevel >= p.RequiredKYCLevel
+}
+
+// MFACode is a one-time login code sent by SMS or email. Only its hash is
+// stored.
+type MFACode struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Method     MFAMethod  `json:"method" db:"method"`
+	CodeHash   string     `json:"-" db:"code_hash"`
+	Attempts   int        `json:"attempts" db:"attempts"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty" db:"consumed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
@@ -14,6 +14,12 @@ type User = pkg.User
 // UserType represents the type of user.
 type UserType = pkg.UserType
 
+// DefaultTenant is the tenant of users not onboarded under a specific one.
+const DefaultTenant = pkg.DefaultTenant
+
+// AdminRole narrows what an admin sees.
+type AdminRole = pkg.AdminRole
+
 // KYCStatus represents the KYC state of a user.
 type KYCStatus = pkg.KYCStatus
 
@@ -108,6 +114,13 @@ const (
 	UserTypeAdmin      = pkg.UserTypeAdmin
 )
 
+// Re-exported admin roles.
+const (
+	AdminRoleSupport    = pkg.AdminRoleSupport
+	AdminRoleCompliance = pkg.AdminRoleCompliance
+	AdminRoleSuperAdmin = pkg.AdminRoleSuperAdmin
+)
+
 // Re-exported KYC statuses.
 const (
 	KYCStatusPending    = pkg.KYCStatusPending
@@ -116,6 +129,33 @@ const (
 	KYCStatusRejected   = pkg.KYCStatusRejected
 )
 
+// KYCDocumentStatus is where one uploaded KYC document is in review.
+type KYCDocumentStatus = pkg.KYCDocumentStatus
+
+// Re-exported KYC document statuses.
+const (
+	KYCDocumentUploaded      = pkg.KYCDocumentUploaded
+	KYCDocumentScanning      = pkg.KYCDocumentScanning
+	KYCDocumentScannedClean  = pkg.KYCDocumentScannedClean
+	KYCDocumentInReview      = pkg.KYCDocumentInReview
+	KYCDocumentAccepted      = pkg.KYCDocumentAccepted
+	KYCDocumentRejected      = pkg.KYCDocumentRejected
+	KYCDocumentQuarantined   = pkg.KYCDocumentQuarantined
+	KYCDocumentInfoRequested = pkg.KYCDocumentInfoRequested
+)
+
+// VirusScanStatus is the outcome of scanning a KYC document's files.
+type VirusScanStatus = pkg.VirusScanStatus
+
+// Re-exported virus scan statuses.
+const (
+	VirusScanPending  = pkg.VirusScanPending
+	VirusScanClean    = pkg.VirusScanClean
+	VirusScanInfected = pkg.VirusScanInfected
+	VirusScanFailed   = pkg.VirusScanFailed
+	VirusScanSkipped  = pkg.VirusScanSkipped
+)
+
 // Re-exported wallet statuses.
 const (
 	WalletStatusActive    = pkg.WalletStatusActive
@@ -129,6 +169,14 @@ type KYCDocument = pkg.KYCDocument
 // APIKey represents an administrative API key.
 type APIKey = pkg.APIKey
 
+// APIEnvironment is the environment an API key works in.
+type APIEnvironment = pkg.APIEnvironment
+
+const (
+	APIEnvironmentProduction = pkg.APIEnvironmentProduction
+	APIEnvironmentSandbox    = pkg.APIEnvironmentSandbox
+)
+
 // Re-exported transaction statuses.
 const (
 	TransactionStatusPending           = pkg.TransactionStatusPending
@@ -143,17 +191,30 @@ const (
 	TransactionStatusReversed          = pkg.TransactionStatusReversed
 	TransactionStatusCancelled         = pkg.TransactionStatusCancelled
 	TransactionStatusRefunded          = pkg.TransactionStatusRefunded
+	TransactionStatusQueued            = pkg.TransactionStatusQueued
 )
 
+// PendingTransactionStatuses are the states of a payment that was initiated
+// but has not been approved for processing yet, or is queued for its
+// corridor's next window.
+var PendingTransactionStatuses = []TransactionStatus{
+	TransactionStatusPending,
+	TransactionStatusPendingApproval,
+	TransactionStatusQueued,
+}
+
 // Re-exported transaction types.
 const (
-	TransactionTypePayment    = pkg.TransactionTypePayment
-	TransactionTypeTransfer   = pkg.TransactionTypeTransfer
-	TransactionTypeWithdrawal = pkg.TransactionTypeWithdrawal
-	TransactionTypeDeposit    = pkg.TransactionTypeDeposit
-	TransactionTypeRefund     = pkg.TransactionTypeRefund
-	TransactionTypeReversal   = pkg.TransactionTypeReversal
-	TransactionTypeSettlement = pkg.TransactionTypeSettlement
+	TransactionTypePayment        = pkg.TransactionTypePayment
+	TransactionTypeTransfer       = pkg.TransactionTypeTransfer
+	TransactionTypeWithdrawal     = pkg.TransactionTypeWithdrawal
+	TransactionTypeDeposit        = pkg.TransactionTypeDeposit
+	TransactionTypeRefund         = pkg.TransactionTypeRefund
+	TransactionTypeReversal       = pkg.TransactionTypeReversal
+	TransactionTypeSettlement     = pkg.TransactionTypeSettlement
+	TransactionTypeAdjustment     = pkg.TransactionTypeAdjustment
+	TransactionTypeEscheatment    = pkg.TransactionTypeEscheatment
+	TransactionTypeCreditInterest = pkg.TransactionTypeCreditInterest
 )
 
 // Re-exported blockchain networks.
@@ -176,11 +237,15 @@ const (
 
 // Re-exported security constants
 const (
-	SecurityEventTypeBruteForce         = pkg.SecurityEventTypeBruteForce
-	SecurityEventTypeSuspiciousIP       = pkg.SecurityEventTypeSuspiciousIP
-	SecurityEventTypeAdminLoginFailed   = pkg.SecurityEventTypeAdminLoginFailed
-	SecurityEventTypeVelocityLimit      = pkg.SecurityEventTypeVelocityLimit
-	SecurityEventTypeBlockchainMismatch = pkg.SecurityEventTypeBlockchainMismatch
+	SecurityEventTypeBruteForce           = pkg.SecurityEventTypeBruteForce
+	SecurityEventTypeSuspiciousIP         = pkg.SecurityEventTypeSuspiciousIP
+	SecurityEventTypeAdminLoginFailed     = pkg.SecurityEventTypeAdminLoginFailed
+	SecurityEventTypeVelocityLimit        = pkg.SecurityEventTypeVelocityLimit
+	SecurityEventTypeBlockchainMismatch   = pkg.SecurityEventTypeBlockchainMismatch
+	SecurityEventTypeLoginSuccess         = pkg.SecurityEventTypeLoginSuccess
+	SecurityEventTypeMetricAnomaly        = pkg.SecurityEventTypeMetricAnomaly
+	SecurityEventTypeCounterpartyExposure = pkg.SecurityEventTypeCounterpartyExposure
+	SecurityEventTypeRedisDegraded        = pkg.SecurityEventTypeRedisDegraded
 
 	SecuritySeverityCritical = pkg.SecuritySeverityCritical
 	SecuritySeverityHigh     = pkg.SecuritySeverityHigh
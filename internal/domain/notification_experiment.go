@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationExperimentStatus string
+
+const (
+	NotificationExperimentRunning NotificationExperimentStatus = "running"
+	NotificationExperimentStopped NotificationExperimentStatus = "stopped"
+)
+
+// NotificationVariant is one version of an event's message under test.
+// Users are split between variants in proportion to their weights. A
+// variant without a subject or body keeps the event's own, so one without
+// either is the control.
+type NotificationVariant struct {
+	Key     string `json:"key"`
+	Weight  int    `json:"weight"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body,omitempty"`
+}
+
+type NotificationVariants []NotificationVariant
+
+func (v NotificationVariants) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+func (v *NotificationVariants) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, v)
+}
+
+// NotificationExperiment tests variants of one kind of notification
+// against each other. An event has at most one running experiment.
+type NotificationExperiment struct {
+	ID        uuid.UUID                    `json:"id" db:"id"`
+	Name      string                       `json:"name" db:"name"`
+	EventType string                       `json:"event_type" db:"event_type"`
+	Status    NotificationExperimentStatus `json:"status" db:"status"`
+	Variants  NotificationVariants         `json:"variants" db:"variants"`
+	CreatedBy *uuid.UUID                   `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time                    `json:"created_at" db:"created_at"`
+	StoppedAt *time.Time                   `json:"stopped_at,omitempty" db:"stopped_at"`
+}
+
+// NotificationDelivery is a notification sent under an experiment, with
+// when the user opened it and clicked through from it.
+type NotificationDelivery struct {
+	NotificationID uuid.UUID  `json:"notification_id" db:"notification_id"`
+	ExperimentID   uuid.UUID  `json:"experiment_id" db:"experiment_id"`
+	Variant        string     `json:"variant" db:"variant"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	DeliveredAt    time.Time  `json:"delivered_at" db:"delivered_at"`
+	OpenedAt       *time.Time `json:"opened_at,omitempty" db:"opened_at"`
+	ClickedAt      *time.Time `json:"clicked_at,omitempty" db:"clicked_at"`
+}
+
+// NotificationVariantResult sums up a variant's deliveries. Users counts
+// the distinct users the variant reached; the rates are shares of
+// Delivered.
+type NotificationVariantResult struct {
+	Variant   string  `json:"variant" db:"variant"`
+	Users     int     `json:"users" db:"users"`
+	Delivered int     `json:"delivered" db:"delivered"`
+	Opened    int     `json:"opened" db:"opened"`
+	Clicked   int     `json:"clicked" db:"clicked"`
+	OpenRate  float64 `json:"open_rate" db:"-"`
+	ClickRate float64 `json:"click_rate" db:"-"`
+}
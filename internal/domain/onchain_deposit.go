@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type OnchainDepositStatus string
+
+const (
+	OnchainDepositDetected  OnchainDepositStatus = "detected"
+	OnchainDepositConfirmed OnchainDepositStatus = "confirmed"
+	OnchainDepositCredited  OnchainDepositStatus = "credited"
+	OnchainDepositUnmatched OnchainDepositStatus = "unmatched"
+	OnchainDepositOrphaned  OnchainDepositStatus = "orphaned"
+	OnchainDepositFailed    OnchainDepositStatus = "failed"
+)
+
+// OnchainDeposit tracks an inbound transfer to one of our receiving addresses
+// from detection through confirmation and wallet credit.
+type OnchainDeposit struct {
+	ID               uuid.UUID            `json:"id" db:"id"`
+	Network          BlockchainNetwork    `json:"network" db:"network"`
+	TxHash           string               `json:"tx_hash" db:"tx_hash"`
+	ReceivingAddress string               `json:"receiving_address" db:"receiving_address"`
+	SenderAddress    string               `json:"sender_address" db:"sender_address"`
+	Memo             string               `json:"memo" db:"memo"`
+	Amount           decimal.Decimal      `json:"amount" db:"amount"`
+	Currency         Currency             `json:"currency" db:"currency"`
+	BlockHeight      int64                `json:"block_height" db:"block_height"`
+	BlockHash        string               `json:"block_hash" db:"block_hash"`
+	Confirmations    int64                `json:"confirmations" db:"confirmations"`
+	Status           OnchainDepositStatus `json:"status" db:"status"`
+	WalletID         *uuid.UUID           `json:"wallet_id,omitempty" db:"wallet_id"`
+	CreditReference  *string              `json:"credit_reference,omitempty" db:"credit_reference"`
+	FailureReason    *string              `json:"failure_reason,omitempty" db:"failure_reason"`
+	ConfirmedAt      *time.Time           `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	CreditedAt       *time.Time           `json:"credited_at,omitempty" db:"credited_at"`
+	CreatedAt        time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// OutboxEventType names a domain event published to other services.
+type OutboxEventType string
+
+const (
+	OutboxPaymentCompleted    OutboxEventType = "payment.completed"
+	OutboxKYCApproved         OutboxEventType = "kyc.approved"
+	OutboxSettlementConfirmed OutboxEventType = "settlement.confirmed"
+)
+
+// OutboxEvent is a domain event written in the same database transaction as
+// the change it describes, waiting to be published or already published.
+type OutboxEvent struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	Type          OutboxEventType `json:"type" db:"event_type"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id" db:"aggregate_id"`
+	Payload       JSONPayload     `json:"payload" db:"payload"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	LastError     string          `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty" db:"published_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// PaymentCompletedEvent is the payload of payment.completed.
+type PaymentCompletedEvent struct {
+	TransactionID     uuid.UUID       `json:"transaction_id"`
+	Reference         string          `json:"reference"`
+	ReferenceNumber   string          `json:"reference_number,omitempty"`
+	TransactionType   TransactionType `json:"transaction_type"`
+	SenderID          uuid.UUID       `json:"sender_id"`
+	ReceiverID        uuid.UUID       `json:"receiver_id"`
+	Amount            decimal.Decimal `json:"amount"`
+	Currency          Currency        `json:"currency"`
+	ConvertedAmount   decimal.Decimal `json:"converted_amount"`
+	ConvertedCurrency Currency        `json:"converted_currency"`
+	FeeAmount         decimal.Decimal `json:"fee_amount"`
+	SettlementID      *uuid.UUID      `json:"settlement_id,omitempty"`
+	CompletedAt       *time.Time      `json:"completed_at,omitempty"`
+}
+
+// KYCApprovedEvent is the payload of kyc.approved.
+type KYCApprovedEvent struct {
+	UserID     uuid.UUID `json:"user_id"`
+	ApprovedAt time.Time `json:"approved_at"`
+}
+
+// SettlementConfirmedEvent is the payload of settlement.confirmed.
+type SettlementConfirmedEvent struct {
+	SettlementID     uuid.UUID         `json:"settlement_id"`
+	BatchReference   string            `json:"batch_reference"`
+	Network          BlockchainNetwork `json:"network"`
+	TransactionHash  string            `json:"transaction_hash,omitempty"`
+	TotalAmount      decimal.Decimal   `json:"total_amount"`
+	Currency         Currency          `json:"currency"`
+	TransactionCount int               `json:"transaction_count"`
+	ConfirmedAt      *time.Time        `json:"confirmed_at,omitempty"`
+}
+
+// NewOutboxEvent returns an event of type t about the aggregate, ready to
+// be published at once.
+func NewOutboxEvent(t OutboxEventType, aggregateType string, aggregateID uuid.UUID, payload interface{}) (*OutboxEvent, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &OutboxEvent{
+		ID:            uuid.New(),
+		Type:          t,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       body,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// PaymentCompleted describes tx reaching completed.
+func PaymentCompleted(tx *Transaction) (*OutboxEvent, error) {
+	return NewOutboxEvent(OutboxPaymentCompleted, "transaction", tx.ID, PaymentCompletedEvent{
+		TransactionID:     tx.ID,
+		Reference:         tx.Reference,
+		ReferenceNumber:   tx.ReferenceNumber,
+		TransactionType:   tx.TransactionType,
+		SenderID:          tx.SenderID,
+		ReceiverID:        tx.ReceiverID,
+		Amount:            tx.Amount,
+		Currency:          tx.Currency,
+		ConvertedAmount:   tx.ConvertedAmount,
+		ConvertedCurrency: tx.ConvertedCurrency,
+		FeeAmount:         tx.FeeAmount,
+		SettlementID:      tx.SettlementID,
+		CompletedAt:       tx.CompletedAt,
+	})
+}
+
+// KYCApproved describes a user's KYC being verified.
+func KYCApproved(userID uuid.UUID, at time.Time) (*OutboxEvent, error) {
+	return NewOutboxEvent(OutboxKYCApproved, "user", userID, KYCApprovedEvent{UserID: userID, ApprovedAt: at.UTC()})
+}
+
+// SettlementConfirmed describes s being confirmed on its network.
+func SettlementConfirmed(s *Settlement) (*OutboxEvent, error) {
+	return NewOutboxEvent(OutboxSettlementConfirmed, "settlement", s.ID, SettlementConfirmedEvent{
+		SettlementID:     s.ID,
+		BatchReference:   s.BatchReference,
+		Network:          s.Network,
+		TransactionHash:  s.TransactionHash,
+		TotalAmount:      s.TotalAmount,
+		Currency:         s.Currency,
+		TransactionCount: s.TransactionCount,
+		ConfirmedAt:      s.ConfirmedAt,
+	})
+}
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PaymentBatchStatus string
+
+const (
+	PaymentBatchQueued     PaymentBatchStatus = "queued"
+	PaymentBatchProcessing PaymentBatchStatus = "processing"
+	PaymentBatchCompleted  PaymentBatchStatus = "completed"
+)
+
+type PaymentBatchItemStatus string
+
+const (
+	// PaymentBatchItemInvalid items failed validation and are never paid.
+	PaymentBatchItemInvalid PaymentBatchItemStatus = "invalid"
+	PaymentBatchItemPending PaymentBatchItemStatus = "pending"
+	// PaymentBatchItemSucceeded items became a payment, which settles like
+	// any other.
+	PaymentBatchItemSucceeded PaymentBatchItemStatus = "succeeded"
+	// PaymentBatchItemFailed items were valid on upload but the payment was
+	// refused, e.g. for want of funds or by a limit.
+	PaymentBatchItemFailed PaymentBatchItemStatus = "failed"
+)
+
+// PaymentBatch is a file of payments a customer uploaded, such as a
+// payroll. Its valid items are paid one by one in the background.
+type PaymentBatch struct {
+	ID        uuid.UUID          `json:"id" db:"id"`
+	UserID    uuid.UUID          `json:"user_id" db:"user_id"`
+	FileName  string             `json:"file_name" db:"file_name"`
+	Format    string             `json:"format" db:"format"`
+	Reference string             `json:"reference,omitempty" db:"reference"`
+	Status    PaymentBatchStatus `json:"status" db:"status"`
+	// TotalItems counts every item in the file, valid or not.
+	TotalItems   int `json:"total_items" db:"total_items"`
+	InvalidItems int `json:"invalid_items" db:"invalid_items"`
+	// ProcessedItems counts valid items done so far, succeeded or failed.
+	ProcessedItems int        `json:"processed_items" db:"processed_items"`
+	SucceededItems int        `json:"succeeded_items" db:"succeeded_items"`
+	FailedItems    int        `json:"failed_items" db:"failed_items"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	StartedAt      *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ValidItems is how many items are paid.
+func (b *PaymentBatch) ValidItems() int {
+	return b.TotalItems - b.InvalidItems
+}
+
+// Progress is the share of valid items processed, from 0 to 1.
+func (b *PaymentBatch) Progress() float64 {
+	if b.ValidItems() <= 0 {
+		return 1
+	}
+	return float64(b.ProcessedItems) / float64(b.ValidItems())
+}
+
+// PaymentBatchRecord is one payment as written in the file. The fields are
+// kept as sent, so an invalid item can be reported back unchanged.
+type PaymentBatchRecord struct {
+	ReceiverID          string `json:"receiver_id"`
+	Amount              string `json:"amount"`
+	Currency            string `json:"currency"`
+	DestinationCurrency string `json:"destination_currency,omitempty"`
+	Description         string `json:"description,omitempty"`
+}
+
+func (r PaymentBatchRecord) Value() (driver.Value, error) {
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+func (r *PaymentBatchRecord) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return errors.New("unsupported payment batch record type")
+}
+
+// PaymentBatchFieldError is why an item failed validation or payment.
+type PaymentBatchFieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// PaymentBatchErrors is stored as JSON.
+type PaymentBatchErrors []PaymentBatchFieldError
+
+func (e PaymentBatchErrors) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(e)
+	return string(b), err
+}
+
+func (e *PaymentBatchErrors) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, e)
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	}
+	return errors.New("unsupported payment batch errors type")
+}
+
+// PaymentBatchItem is one payment of a batch and what became of it. Line is
+// the CSV line number, the header being line 1, or the payment's 1-based
+// position in a JSON file.
+type PaymentBatchItem struct {
+	BatchID       uuid.UUID              `json:"batch_id" db:"batch_id"`
+	Line          int                    `json:"line" db:"line"`
+	Record        PaymentBatchRecord     `json:"record" db:"record"`
+	Status        PaymentBatchItemStatus `json:"status" db:"status"`
+	Errors        PaymentBatchErrors     `json:"errors,omitempty" db:"errors"`
+	TransactionID *uuid.UUID             `json:"transaction_id,omitempty" db:"transaction_id"`
+	ProcessedAt   *time.Time             `json:"processed_at,omitempty" db:"processed_at"`
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PaymentDisclosure is what a sender is told a payment will cost and deliver
+// before they send it, laid out as remittance transfer rules require: the
+// amount and fees in the sending currency, the exchange rate and its margin
+// over the mid-market rate, what the recipient receives and when. It is kept
+// as issued and linked to the payment made under it, for audit.
+type PaymentDisclosure struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	UserID           uuid.UUID  `json:"user_id" db:"user_id"`
+	SenderWalletID   uuid.UUID  `json:"sender_wallet_id" db:"sender_wallet_id"`
+	ReceiverWalletID uuid.UUID  `json:"receiver_wallet_id" db:"receiver_wallet_id"`
+	RecipientName    string     `json:"recipient_name" db:"recipient_name"`
+	TransactionID    *uuid.UUID `json:"transaction_id,omitempty" db:"transaction_id"`
+
+	TransferAmount   decimal.Decimal `json:"transfer_amount" db:"transfer_amount"`
+	TransferCurrency Currency        `json:"transfer_currency" db:"transfer_currency"`
+	TransferFees     decimal.Decimal `json:"transfer_fees" db:"transfer_fees"`
+	TransferTaxes    decimal.Decimal `json:"transfer_taxes" db:"transfer_taxes"`
+	// TotalCost is what the sender pays: the amount, fees and taxes.
+	TotalCost decimal.Decimal `json:"total_cost" db:"total_cost"`
+
+	// ExchangeRate is the rate applied; MidMarketRate the rate it is priced
+	// off. FXMarginPercent is the difference as a share of the mid-market
+	// rate, and FXMarginAmount what it costs the recipient.
+	ExchangeRate    decimal.Decimal `json:"exchange_rate" db:"exchange_rate"`
+	MidMarketRate   decimal.Decimal `json:"mid_market_rate" db:"mid_market_rate"`
+	FXMarginPercent decimal.Decimal `json:"fx_margin_percent" db:"fx_margin_percent"`
+	FXMarginAmount  decimal.Decimal `json:"fx_margin_amount" db:"fx_margin_amount"`
+
+	ReceiveAmount   decimal.Decimal `json:"receive_amount" db:"receive_amount"`
+	ReceiveCurrency Currency        `json:"receive_currency" db:"receive_currency"`
+	// OtherFees are charged to the recipient by others, such as the
+	// receiving rail.
+	OtherFees        decimal.Decimal `json:"other_fees" db:"other_fees"`
+	TotalToRecipient decimal.Decimal `json:"total_to_recipient" db:"total_to_recipient"`
+
+	// AvailableBy is when the funds are expected to have reached the
+	// recipient's network. SubjectToReview payments are held for a manual
+	// review first, which can delay them past it.
+	AvailableBy     time.Time `json:"available_by" db:"available_by"`
+	SubjectToReview bool      `json:"subject_to_review" db:"subject_to_review"`
+	// RightsNotice tells the sender how to cancel and how to raise an error.
+	RightsNotice string `json:"rights_notice" db:"rights_notice"`
+
+	IssuedAt  time.Time `json:"issued_at" db:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// IsExpired reports whether the disclosure can no longer be paid under at
+// now.
+func (d *PaymentDisclosure) IsExpired(now time.Time) bool {
+	return !now.Before(d.ExpiresAt)
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "github.com/google/uuid"
+
+// PaymentPartiesQuery identifies both sides of a payment being initiated.
+// The receiver is found by ReceiverAddress when set, otherwise by
+// ReceiverID and ReceiverCurrency.
+type PaymentPartiesQuery struct {
+	SenderID         uuid.UUID
+	Currency         Currency
+	ReceiverAddress  string
+	ReceiverID       uuid.UUID
+	ReceiverCurrency Currency
+}
+
+// PaymentParties is what payment initiation reads about both sides, loaded
+// in one round trip. Sender carries only the unencrypted fields the checks
+// use (ID, type, KYC status and level, country, creation time), and
+// ReceiverCountry is the country of ReceiverWallet's owner. Missing wallets
+// are nil; Sender is nil only when SenderWallet is.
+type PaymentParties struct {
+	Sender          *User
+	SenderWallet    *Wallet
+	ReceiverWallet  *Wallet
+	ReceiverCountry string
+}
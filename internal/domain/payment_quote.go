@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PaymentQuote is a price given for a payment before it is made: what the
+// receiver gets at the exchange rate of the moment, and the fees. A payment
+// initiated under the quote before it expires is converted at its rate,
+// whatever the market has done since. Quotes are short-lived and kept only
+// until they expire or are used.
+type PaymentQuote struct {
+	ID               uuid.UUID `json:"quote_id"`
+	SenderID         uuid.UUID `json:"sender_id"`
+	SenderWalletID   uuid.UUID `json:"sender_wallet_id"`
+	ReceiverWalletID uuid.UUID `json:"receiver_wallet_id"`
+
+	Amount   decimal.Decimal `json:"amount"`
+	Currency Currency        `json:"currency"`
+	// ExchangeRate is the rate locked for the payment; MidMarketRate the
+	// rate it was priced off. Both are 1 within one currency.
+	ExchangeRate        decimal.Decimal `json:"exchange_rate"`
+	MidMarketRate       decimal.Decimal `json:"mid_market_rate"`
+	ConvertedAmount     decimal.Decimal `json:"converted_amount"`
+	DestinationCurrency Currency        `json:"destination_currency"`
+	Fees                *FeeBreakdown   `json:"fees"`
+	TotalDebit          decimal.Decimal `json:"total_debit"`
+
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IsExpired reports whether the quote can no longer be paid under at now.
+func (q *PaymentQuote) IsExpired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
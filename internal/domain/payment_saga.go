@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PaymentSagaStep is the last step of a payment initiation known to have
+// taken effect.
+type PaymentSagaStep string
+
+const (
+	// PaymentSagaStarted: checks passed and the saga was written; the
+	// transaction may or may not have been recorded.
+	PaymentSagaStarted PaymentSagaStep = "started"
+	// PaymentSagaRecorded: the transaction was recorded; nothing moved yet.
+	PaymentSagaRecorded PaymentSagaStep = "recorded"
+	// PaymentSagaReserved: a queued payment's funds were reserved. Recorded
+	// in the same database transaction as the reservation.
+	PaymentSagaReserved PaymentSagaStep = "reserved"
+	// PaymentSagaPosted: the ledger posting committed and the money moved.
+	PaymentSagaPosted PaymentSagaStep = "posted"
+)
+
+// PaymentSagaState is whether a saga still needs recovering.
+type PaymentSagaState string
+
+const (
+	PaymentSagaRunning PaymentSagaState = "running"
+	// PaymentSagaCompleted sagas left the transaction where initiation
+	// meant it to rest: settling, queued or held for review.
+	PaymentSagaCompleted PaymentSagaState = "completed"
+	// PaymentSagaCompensated sagas stopped before money moved and left the
+	// transaction failed, or never recorded it.
+	PaymentSagaCompensated PaymentSagaState = "compensated"
+)
+
+// PaymentSaga tracks one payment initiation through the steps that move
+// money, so that one interrupted by a crash can be finished on recovery.
+type PaymentSaga struct {
+	TransactionID  uuid.UUID        `json:"transaction_id" db:"transaction_id"`
+	Reference      string           `json:"reference" db:"reference"`
+	SenderWalletID uuid.UUID        `json:"sender_wallet_id" db:"sender_wallet_id"`
+	TotalDebit     decimal.Decimal  `json:"total_debit" db:"total_debit"`
+	Currency       Currency         `json:"currency" db:"currency"`
+	Step           PaymentSagaStep  `json:"step" db:"step"`
+	State          PaymentSagaState `json:"state" db:"state"`
+	// Outcome says how a finished saga ended, e.g. which compensation ran.
+	Outcome    string     `json:"outcome" db:"outcome"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+}
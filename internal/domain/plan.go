@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PlanCode identifies a product plan.
+type PlanCode string
+
+const (
+	PlanConsumer PlanCode = "consumer"
+	PlanPremium  PlanCode = "premium"
+	PlanBusiness PlanCode = "business"
+)
+
+// Entitlements are what a plan allows. Fees, limits, settlement routing and
+// API access all read them through one check, so a plan change applies
+// everywhere at once.
+type Entitlements struct {
+	Plan PlanCode `json:"plan"`
+	// LimitMultiplier scales the KYC tier's per-transaction and daily
+	// limits. The platform-wide risk limits still apply on top.
+	LimitMultiplier decimal.Decimal `json:"limit_multiplier"`
+	// FeeRate is the share of the amount charged on a payment.
+	FeeRate decimal.Decimal `json:"fee_rate"`
+	// PrioritySettlement puts payments in the priority settlement lane,
+	// settled in their own batches with the fast fee strategy.
+	PrioritySettlement bool `json:"priority_settlement"`
+	// APIAccess allows API keys owned by the account.
+	APIAccess bool `json:"api_access"`
+}
+
+// Plan is a product tier with a monthly fee.
+type Plan struct {
+	Code        PlanCode        `json:"code"`
+	Name        string          `json:"name"`
+	MonthlyFee  decimal.Decimal `json:"monthly_fee"`
+	FeeCurrency Currency        `json:"fee_currency"`
+	// MerchantOnly plans can only be chosen by merchant accounts; admins
+	// can still assign them to anyone.
+	MerchantOnly bool         `json:"merchant_only"`
+	Entitlements Entitlements `json:"entitlements"`
+}
+
+// PlanSubscription is the plan a user is on. PendingPlan is a downgrade
+// that takes effect at PeriodEnd.
+type PlanSubscription struct {
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Plan        PlanCode  `json:"plan" db:"plan"`
+	PendingPlan *PlanCode `json:"pending_plan,omitempty" db:"pending_plan"`
+	PeriodStart time.Time `json:"period_start" db:"period_start"`
+	PeriodEnd   time.Time `json:"period_end" db:"period_end"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PlanChange describes a plan change for proration and billing. Proration
+// is the amount owed for the rest of the current period on the new plan,
+// negative for a credit; it is zero for changes that wait for the period
+// end.
+type PlanChange struct {
+	UserID      uuid.UUID       `json:"user_id"`
+	From        PlanCode        `json:"from"`
+	To          PlanCode        `json:"to"`
+	EffectiveAt time.Time       `json:"effective_at"`
+	Proration   decimal.Decimal `json:"proration"`
+	Currency    Currency        `json:"currency"`
+	ChangedBy   uuid.UUID       `json:"changed_by"`
+}
+
+// SettlementLaneKey is the transaction metadata key naming the settlement
+// lane; SettlementLanePriority marks payments from plans with priority
+// settlement.
+const (
+	SettlementLaneKey      = "settlement_lane"
+	SettlementLanePriority = "priority"
+)
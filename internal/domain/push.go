@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PushProvider is the service that delivers push notifications to a
+// device.
+type PushProvider string
+
+const (
+	// PushProviderFCM is Firebase Cloud Messaging, used by the Android app.
+	PushProviderFCM PushProvider = "fcm"
+	// PushProviderAPNs is the Apple Push Notification service.
+	PushProviderAPNs PushProvider = "apns"
+)
+
+// PushToken is a device the app registered to receive a user's push
+// notifications. A token belongs to one app install, so registering it for
+// another user moves it.
+type PushToken struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	UserID     uuid.UUID    `json:"user_id" db:"user_id"`
+	Provider   PushProvider `json:"provider" db:"provider"`
+	Token      string       `json:"-" db:"token"`
+	DeviceName string       `json:"device_name,omitempty" db:"device_name"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// NotificationChannel is a way of reaching users that they may turn off
+// per kind of notification. In-app notifications are always kept.
+type NotificationChannel string
+
+const (
+	NotificationChannelSMS  NotificationChannel = "sms"
+	NotificationChannelPush NotificationChannel = "push"
+)
+
+// NotificationChannels are the channels users have preferences for.
+var NotificationChannels = []NotificationChannel{NotificationChannelPush, NotificationChannelSMS}
+
+// NotificationPreference turns a channel on or off for one kind of
+// notification. Without one, the channel is on.
+type NotificationPreference struct {
+	EventType string              `json:"event_type" db:"event_type"`
+	Channel   NotificationChannel `json:"channel" db:"channel"`
+	Enabled   bool                `json:"enabled" db:"enabled"`
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// RateResolution is the period a RateBar covers.
+type RateResolution string
+
+const (
+	RateMinute RateResolution = "minute"
+	RateHour   RateResolution = "hour"
+	RateDay    RateResolution = "day"
+)
+
+// RateResolutions are the resolutions rate history is kept at, finest
+// first.
+var RateResolutions = []RateResolution{RateMinute, RateHour, RateDay}
+
+// Valid reports whether r is a known resolution.
+func (r RateResolution) Valid() bool {
+	switch r {
+	case RateMinute, RateHour, RateDay:
+		return true
+	}
+	return false
+}
+
+// Duration is the length of one bar.
+func (r RateResolution) Duration() time.Duration {
+	switch r {
+	case RateHour:
+		return time.Hour
+	case RateDay:
+		return 24 * time.Hour
+	}
+	return time.Minute
+}
+
+// BucketStart returns the start of the bar containing t, in UTC.
+func (r RateResolution) BucketStart(t time.Time) time.Time {
+	return t.UTC().Truncate(r.Duration())
+}
+
+// RateBar is the open, high, low and close mid-market rate of a currency
+// pair over one minute, hour or day, from the rates sampled in it.
+type RateBar struct {
+	BaseCurrency   Currency        `json:"base_currency" db:"base_currency"`
+	TargetCurrency Currency        `json:"target_currency" db:"target_currency"`
+	Resolution     RateResolution  `json:"resolution" db:"resolution"`
+	BucketStart    time.Time       `json:"time" db:"bucket_start"`
+	Open           decimal.Decimal `json:"open" db:"open"`
+	High           decimal.Decimal `json:"high" db:"high"`
+	Low            decimal.Decimal `json:"low" db:"low"`
+	Close          decimal.Decimal `json:"close" db:"close"`
+	Samples        int             `json:"samples" db:"samples"`
+	// FirstAt and LastAt are when the opening and closing samples were
+	// taken.
+	FirstAt time.Time `json:"first_at" db:"first_at"`
+	LastAt  time.Time `json:"last_at" db:"last_at"`
+}
+
+// TransactionRate is the exchange rate a transaction was converted at,
+// against the market at the time, for audit.
+type TransactionRate struct {
+	TransactionID   uuid.UUID       `json:"transaction_id"`
+	Reference       string          `json:"reference"`
+	From            Currency        `json:"from"`
+	To              Currency        `json:"to"`
+	Amount          decimal.Decimal `json:"amount"`
+	ConvertedAmount decimal.Decimal `json:"converted_amount"`
+	// AppliedRate is the rate the transaction was converted at, and
+	// MidMarketRate the mid-market rate it was priced off when recorded.
+	AppliedRate   decimal.Decimal  `json:"applied_rate"`
+	MidMarketRate *decimal.Decimal `json:"mid_market_rate,omitempty"`
+	// QuoteID is set when the rate was locked by a payment quote, in
+	// which case it was priced when the quote was issued.
+	QuoteID     string    `json:"quote_id,omitempty"`
+	ConvertedAt time.Time `json:"converted_at"`
+	// Market is the finest bar still kept that covers ConvertedAt, and
+	// MarkupPct how far the applied rate was below its close, in percent.
+	Market    *RateBar         `json:"market,omitempty"`
+	MarkupPct *decimal.Decimal `json:"markup_pct,omitempty"`
+}
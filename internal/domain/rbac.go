@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role is a named set of staff permissions. Roles are only granted to admin
+// accounts; customers never hold any.
+type Role string
+
+const (
+	RoleAdmin             Role = "admin"
+	RoleComplianceOfficer Role = "compliance_officer"
+	RoleSupport           Role = "support"
+	RoleTreasury          Role = "treasury"
+)
+
+// ErrLastAdmin is returned instead of taking the admin role from its only
+// holder.
+var ErrLastAdmin = errors.New("last holder of the admin role")
+
+// Permission allows one kind of access to a group of admin endpoints.
+type Permission string
+
+const (
+	// PermissionAll is held by the admin role and allows everything.
+	PermissionAll Permission = "*"
+
+	PermissionUsersRead         Permission = "users:read"
+	PermissionUsersWrite        Permission = "users:write"
+	PermissionPersonalData      Permission = "users:personal_data"
+	PermissionUsersUnmask       Permission = "users:unmask"
+	PermissionComplianceRead    Permission = "compliance:read"
+	PermissionComplianceWrite   Permission = "compliance:write"
+	PermissionTransactionsRead  Permission = "transactions:read"
+	PermissionTransactionsWrite Permission = "transactions:write"
+	PermissionTreasuryRead      Permission = "treasury:read"
+	PermissionTreasuryWrite     Permission = "treasury:write"
+	PermissionAnalyticsRead     Permission = "analytics:read"
+	PermissionMessagingRead     Permission = "messaging:read"
+	PermissionMessagingWrite    Permission = "messaging:write"
+	PermissionSystemRead        Permission = "system:read"
+	PermissionSystemWrite       Permission = "system:write"
+	PermissionRolesManage       Permission = "roles:manage"
+)
+
+// Permissions lists every permission other than PermissionAll.
+var Permissions = []Permission{
+	PermissionUsersRead, PermissionUsersWrite, PermissionPersonalData, PermissionUsersUnmask,
+	PermissionComplianceRead, PermissionComplianceWrite,
+	PermissionTransactionsRead, PermissionTransactionsWrite,
+	PermissionTreasuryRead, PermissionTreasuryWrite,
+	PermissionAnalyticsRead,
+	PermissionMessagingRead, PermissionMessagingWrite,
+	PermissionSystemRead, PermissionSystemWrite,
+	PermissionRolesManage,
+}
+
+// PermissionSet is what a staff member may do, the union of their roles.
+type PermissionSet map[Permission]bool
+
+// NewPermissionSet returns a set holding perms.
+func NewPermissionSet(perms ...Permission) PermissionSet {
+	s := make(PermissionSet, len(perms))
+	for _, p := range perms {
+		s[p] = true
+	}
+	return s
+}
+
+// Has reports whether the set allows p.
+func (s PermissionSet) Has(p Permission) bool {
+	return s[PermissionAll] || s[p]
+}
+
+// RoleDefinition is a role with the permissions it grants.
+type RoleDefinition struct {
+	Name        Role         `json:"name" db:"name"`
+	Description string       `json:"description" db:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// RoleAssignment records that a user holds a role.
+type RoleAssignment struct {
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Role      Role       `json:"role" db:"role"`
+	GrantedBy *uuid.UUID `json:"granted_by,omitempty" db:"granted_by"`
+	GrantedAt time.Time  `json:"granted_at" db:"granted_at"`
+}
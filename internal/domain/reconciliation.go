@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ReconciliationCheck names one of the scheduled reconciliation checks.
+type ReconciliationCheck string
+
+const (
+	// ReconcileWalletLedger: a wallet's ledger balance differs from the sum
+	// of its ledger entries.
+	ReconcileWalletLedger ReconciliationCheck = "wallet_ledger"
+	// ReconcileWalletSplit: a wallet's ledger balance is not its available
+	// plus its reserved balance.
+	ReconcileWalletSplit ReconciliationCheck = "wallet_split"
+	// ReconcileGeneralLedger: the customer_wallets account differs from
+	// what customers' wallets hold in a currency.
+	ReconcileGeneralLedger ReconciliationCheck = "general_ledger"
+	// ReconcileTrialBalance: the general ledger's debits and credits differ
+	// in a currency.
+	ReconcileTrialBalance ReconciliationCheck = "trial_balance"
+)
+
+// ReconciliationChecks are all checks, in the order they run.
+var ReconciliationChecks = []ReconciliationCheck{
+	ReconcileWalletLedger,
+	ReconcileWalletSplit,
+	ReconcileGeneralLedger,
+	ReconcileTrialBalance,
+}
+
+// ReconciliationBreakStatus is whether a break still holds.
+type ReconciliationBreakStatus string
+
+const (
+	ReconciliationBreakOpen ReconciliationBreakStatus = "open"
+	// ReconciliationBreakResolved breaks were not seen again by a later run
+	// of their check.
+	ReconciliationBreakResolved ReconciliationBreakStatus = "resolved"
+)
+
+// ReconciliationBreak is an amount that should agree with another and does
+// not. Subject is the wallet or, for the ledger-wide checks, the currency.
+type ReconciliationBreak struct {
+	ID          uuid.UUID                 `json:"id" db:"id"`
+	Check       ReconciliationCheck       `json:"check" db:"kind"`
+	Subject     string                    `json:"subject" db:"subject"`
+	Currency    Currency                  `json:"currency" db:"currency"`
+	Expected    decimal.Decimal           `json:"expected" db:"expected"`
+	Actual      decimal.Decimal           `json:"actual" db:"actual"`
+	Detail      string                    `json:"detail" db:"detail"`
+	Status      ReconciliationBreakStatus `json:"status" db:"status"`
+	FirstSeenAt time.Time                 `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt  time.Time                 `json:"last_seen_at" db:"last_seen_at"`
+	ResolvedAt  *time.Time                `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// Difference is how far the actual amount is from the expected one.
+func (b *ReconciliationBreak) Difference() decimal.Decimal {
+	return b.Actual.Sub(b.Expected)
+}
+
+// ReconciliationRun is one pass of the reconciliation checks. Checks that
+// failed to run are listed in Failed and leave their breaks as they were.
+type ReconciliationRun struct {
+	StartedAt  time.Time             `json:"started_at"`
+	FinishedAt time.Time             `json:"finished_at"`
+	Open       int                   `json:"open"`
+	Opened     []ReconciliationBreak `json:"opened"`
+	Resolved   int                   `json:"resolved"`
+	Failed     []string              `json:"failed"`
+}
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StuckReason is why a transaction stopped moving through its lifecycle.
+type StuckReason string
+
+const (
+	// StuckMissingLedgerEntry: a payment never reached the ledger, or
+	// reached it without its status moving on.
+	StuckMissingLedgerEntry StuckReason = "missing_ledger_entry"
+	// StuckNoSettlementRecord: a posted payment is not in any live
+	// settlement.
+	StuckNoSettlementRecord StuckReason = "no_settlement_record"
+	// StuckNotDispatched: a withdrawal was reserved but never sent to its
+	// payout provider.
+	StuckNotDispatched StuckReason = "not_dispatched"
+	// StuckPartnerTimeout: a payout or top-up provider, or the settlement
+	// network, never reported the outcome.
+	StuckPartnerTimeout StuckReason = "partner_timeout"
+	// StuckUnclassified: none of the above.
+	StuckUnclassified StuckReason = "unclassified"
+)
+
+// RecoveryAction is what recovery did about a stuck transaction.
+type RecoveryAction string
+
+const (
+	// RecoveryResubmit posts a payment again under its original idempotency
+	// key, or hands it back to settlement batching.
+	RecoveryResubmit RecoveryAction = "resubmit"
+	// RecoveryReleaseReservation fails a withdrawal that was never sent
+	// and returns its reserved funds.
+	RecoveryReleaseReservation RecoveryAction = "release_reservation"
+	// RecoveryEscalate leaves the transaction as it is for an admin.
+	RecoveryEscalate RecoveryAction = "escalate"
+)
+
+// StuckTransaction is a transaction past the stuck threshold with what
+// recovery needs to classify it.
+type StuckTransaction struct {
+	Transaction
+	HasLedgerEntries bool `db:"has_ledger_entries"`
+	// SettlementStatus is the status of the settlement the transaction
+	// points at; nil when it points at none or at one that does not exist.
+	SettlementStatus *SettlementStatus `db:"settlement_status"`
+}
+
+// TransactionExceptionStatus is where an exception is in the admin queue.
+type TransactionExceptionStatus string
+
+const (
+	TransactionExceptionOpen     TransactionExceptionStatus = "open"
+	TransactionExceptionResolved TransactionExceptionStatus = "resolved"
+)
+
+// TransactionException is a stuck transaction recovery could not fix on
+// its own. A transaction has at most one open exception.
+type TransactionException struct {
+	ID            uuid.UUID                  `json:"id" db:"id"`
+	TransactionID uuid.UUID                  `json:"transaction_id" db:"transaction_id"`
+	Reference     string                     `json:"reference" db:"reference"`
+	Reason        StuckReason                `json:"reason" db:"reason"`
+	Detail        string                     `json:"detail" db:"detail"`
+	Status        TransactionExceptionStatus `json:"status" db:"status"`
+	Resolution    *string                    `json:"resolution,omitempty" db:"resolution"`
+	ResolvedBy    *uuid.UUID                 `json:"resolved_by,omitempty" db:"resolved_by"`
+	ResolvedAt    *time.Time                 `json:"resolved_at,omitempty" db:"resolved_at"`
+	CreatedAt     time.Time                  `json:"created_at" db:"created_at"`
+}
+
+// RecoveryRun counts what one recovery pass did.
+type RecoveryRun struct {
+	Examined  int `json:"examined"`
+	Recovered int `json:"recovered"`
+	Escalated int `json:"escalated"`
+	Failed    int `json:"failed"`
+}
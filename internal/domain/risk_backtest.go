@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// RiskRules is what the risk engine scores payments with and what it does
+// with the score. The live rules come from the RISK_* settings; backtests
+// score past payments with candidate rules to compare them with the live
+// ones.
+type RiskRules struct {
+	// KYCAmountLimits is, per KYC level, the amount above which a payment
+	// is risky for the sender. Levels not listed use level 0's.
+	KYCAmountLimits    map[int]int64 `json:"kyc_amount_limits"`
+	HighValueThreshold int64         `json:"high_value_threshold"`
+	// NewAccountDays is the account age under which high-value payments
+	// score a second time.
+	NewAccountDays     int    `json:"new_account_days"`
+	SuspiciousLocation string `json:"suspicious_location"`
+	// Velocity limits on the sender's payments in the hour before; 0 turns
+	// a limit off.
+	MaxVelocityPerHour int `json:"max_velocity_per_hour"`
+	HighValuePerHour   int `json:"high_value_per_hour"`
+	// AdminApprovalThreshold is the amount from which payments are held for
+	// approval; 0 holds none.
+	AdminApprovalThreshold int64 `json:"admin_approval_threshold"`
+	// Scores at AlertScore or above raise an alert, and at BlockScore or
+	// above block the payment.
+	AlertScore int `json:"alert_score"`
+	BlockScore int `json:"block_score"`
+}
+
+func (r RiskRules) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *RiskRules) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, r)
+}
+
+type RiskBacktestStatus string
+
+const (
+	RiskBacktestQueued    RiskBacktestStatus = "queued"
+	RiskBacktestRunning   RiskBacktestStatus = "running"
+	RiskBacktestCompleted RiskBacktestStatus = "completed"
+	RiskBacktestFailed    RiskBacktestStatus = "failed"
+)
+
+// RiskBacktest replays the payments of a past window through the rules live
+// when it was requested (Baseline) and through Candidate, and compares what
+// each would have done. It reads production data and changes none of it.
+type RiskBacktest struct {
+	ID          uuid.UUID            `json:"id" db:"id"`
+	From        time.Time            `json:"from" db:"window_from"`
+	To          time.Time            `json:"to" db:"window_to"`
+	Baseline    RiskRules            `json:"baseline" db:"baseline"`
+	Candidate   RiskRules            `json:"candidate" db:"candidate"`
+	Note        string               `json:"note,omitempty" db:"note"`
+	Status      RiskBacktestStatus   `json:"status" db:"status"`
+	Processed   int                  `json:"processed" db:"processed"`
+	Results     *RiskBacktestResults `json:"results,omitempty" db:"results"`
+	Error       *string              `json:"error,omitempty" db:"error"`
+	RequestedBy uuid.UUID            `json:"requested_by" db:"requested_by"`
+	StartedAt   *time.Time           `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// RiskBacktestOutcome is what one set of rules would have done to the
+// payments of a backtest.
+type RiskBacktestOutcome struct {
+	Blocked int `json:"blocked"`
+	// BlockedByReason counts blocked payments by the first check that
+	// blocked them: velocity, high_value_velocity or risk_score.
+	BlockedByReason map[string]int               `json:"blocked_by_reason"`
+	BlockedAmount   map[Currency]decimal.Decimal `json:"blocked_amount"`
+	Alerts          int                          `json:"alerts"`
+	Reviews         int                          `json:"reviews"`
+	AverageScore    float64                      `json:"average_score"`
+}
+
+// RiskBacktestChange is a payment the candidate rules treat differently.
+type RiskBacktestChange struct {
+	TransactionID     uuid.UUID       `json:"transaction_id"`
+	Reference         string          `json:"reference"`
+	SenderID          uuid.UUID       `json:"sender_id"`
+	Amount            decimal.Decimal `json:"amount"`
+	Currency          Currency        `json:"currency"`
+	CreatedAt         time.Time       `json:"created_at"`
+	BaselineScore     int             `json:"baseline_score"`
+	CandidateScore    int             `json:"candidate_score"`
+	BaselineDecision  string          `json:"baseline_decision"`
+	CandidateDecision string          `json:"candidate_decision"`
+}
+
+// RiskBacktestResults compares the baseline and candidate outcomes. Deltas
+// are candidate minus baseline.
+type RiskBacktestResults struct {
+	Transactions  int                 `json:"transactions"`
+	Baseline      RiskBacktestOutcome `json:"baseline"`
+	Candidate     RiskBacktestOutcome `json:"candidate"`
+	BlockedDelta  int                 `json:"blocked_delta"`
+	AlertsDelta   int                 `json:"alerts_delta"`
+	ReviewsDelta  int                 `json:"reviews_delta"`
+	NewlyBlocked  int                 `json:"newly_blocked"`
+	Unblocked     int                 `json:"unblocked"`
+	NewAlerts     int                 `json:"new_alerts"`
+	ClearedAlerts int                 `json:"cleared_alerts"`
+	ScoreChanged  int                 `json:"score_changed"`
+	// Changes lists the first payments whose decision changed, oldest
+	// first.
+	Changes []RiskBacktestChange `json:"changes"`
+}
+
+func (r RiskBacktestResults) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *RiskBacktestResults) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, r)
+}
+
+// RiskBacktestPayment is a past payment with what the risk engine knows of
+// its sender.
+type RiskBacktestPayment struct {
+	ID              uuid.UUID         `db:"id"`
+	Reference       string            `db:"reference"`
+	SenderID        uuid.UUID         `db:"sender_id"`
+	Amount          decimal.Decimal   `db:"amount"`
+	Currency        Currency          `db:"currency"`
+	Status          TransactionStatus `db:"status"`
+	CreatedAt       time.Time         `db:"created_at"`
+	KYCLevel        int               `db:"kyc_level"`
+	SenderCreatedAt time.Time         `db:"sender_created_at"`
+}
@@ -0,0 +1,188 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// SegmentVolumeWindow is the period over which a segment's volume bounds
+// are measured.
+const SegmentVolumeWindow = 30 * 24 * time.Hour
+
+// SegmentCriteria selects users. Empty fields do not restrict the segment;
+// the rest must all hold. Admin accounts and inactive users never match.
+type SegmentCriteria struct {
+	Countries        []string   `json:"countries,omitempty"`
+	KYCLevels        []int      `json:"kyc_levels,omitempty"`
+	UserTypes        []UserType `json:"user_types,omitempty"`
+	ActiveWithinDays int        `json:"active_within_days,omitempty"` // logged in within the last N days
+	// Account age bounds, in days since sign-up.
+	MinAccountAgeDays int `json:"min_account_age_days,omitempty"`
+	MaxAccountAgeDays int `json:"max_account_age_days,omitempty"`
+	// Volume bounds on completed payments sent in VolumeCurrency over the
+	// last SegmentVolumeWindow.
+	VolumeCurrency Currency         `json:"volume_currency,omitempty"`
+	MinVolume      *decimal.Decimal `json:"min_volume,omitempty"`
+	MaxVolume      *decimal.Decimal `json:"max_volume,omitempty"`
+}
+
+func (c SegmentCriteria) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+func (c *SegmentCriteria) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, c)
+}
+
+// NeedsVolume reports whether matching requires the user's volume.
+func (c SegmentCriteria) NeedsVolume() bool {
+	return c.MinVolume != nil || c.MaxVolume != nil
+}
+
+// Matches reports whether u is in the segment at now, given u's volume in
+// VolumeCurrency (ignored unless NeedsVolume). It must agree with the SQL
+// the repository builds for the same criteria.
+func (c SegmentCriteria) Matches(u *User, volume decimal.Decimal, now time.Time) bool {
+	if u == nil || !u.IsActive || u.UserStatus != UserStatusActive || u.UserType == UserTypeAdmin {
+		return false
+	}
+	if len(c.Countries) > 0 && !containsString(c.Countries, u.CountryCode) {
+		return false
+	}
+	if len(c.KYCLevels) > 0 {
+		found := false
+		for _, l := range c.KYCLevels {
+			if l == u.KYCLevel {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(c.UserTypes) > 0 {
+		found := false
+		for _, t := range c.UserTypes {
+			if t == u.UserType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.ActiveWithinDays > 0 && (u.LastLogin == nil || u.LastLogin.Before(now.AddDate(0, 0, -c.ActiveWithinDays))) {
+		return false
+	}
+	if c.MinAccountAgeDays > 0 && u.CreatedAt.After(now.AddDate(0, 0, -c.MinAccountAgeDays)) {
+		return false
+	}
+	if c.MaxAccountAgeDays > 0 && !u.CreatedAt.After(now.AddDate(0, 0, -c.MaxAccountAgeDays)) {
+		return false
+	}
+	if c.MinVolume != nil && volume.LessThan(*c.MinVolume) {
+		return false
+	}
+	if c.MaxVolume != nil && volume.GreaterThan(*c.MaxVolume) {
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Segment is a named, reusable set of criteria. Fees, risk scoring, feature
+// flags and broadcasts refer to segments instead of repeating criteria.
+type Segment struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	Key         string          `json:"key" db:"key"`
+	Name        string          `json:"name" db:"name"`
+	Description string          `json:"description,omitempty" db:"description"`
+	Criteria    SegmentCriteria `json:"criteria" db:"criteria"`
+	// FeeRate, when set, replaces the plan's payment fee rate for members.
+	FeeRate *decimal.Decimal `json:"fee_rate,omitempty" db:"fee_rate"`
+	// RiskAdjustment is added to the risk score of members' payments.
+	RiskAdjustment int        `json:"risk_adjustment" db:"risk_adjustment"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// SegmentPolicy is what a user's segments change about their payments.
+type SegmentPolicy struct {
+	Segments []uuid.UUID
+	// FeeRate is the lowest fee rate among the user's segments, or nil.
+	FeeRate        *decimal.Decimal
+	RiskAdjustment int
+}
+
+// FeatureFlag turns a feature on for everyone or for members of the listed
+// segments.
+type FeatureFlag struct {
+	Key         string `json:"key" db:"key"`
+	Description string `json:"description,omitempty" db:"description"`
+	Enabled     bool   `json:"enabled" db:"enabled"`
+	// Segments holds segment IDs; empty means everyone.
+	Segments  pq.StringArray `json:"segments" db:"segments"`
+	UpdatedBy *uuid.UUID     `json:"updated_by,omitempty" db:"updated_by"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// Normalize trims and upper-cases country and currency codes and checks
+// that the criteria are consistent.
+func (c *SegmentCriteria) Normalize() error {
+	for i, cc := range c.Countries {
+		c.Countries[i] = strings.ToUpper(strings.TrimSpace(cc))
+		if len(c.Countries[i]) != 2 {
+			return fmt.Errorf("invalid country code %q", cc)
+		}
+	}
+	for _, t := range c.UserTypes {
+		if t != UserTypeIndividual && t != UserTypeMerchant && t != UserTypeAgent {
+			return fmt.Errorf("invalid user type %q", t)
+		}
+	}
+	if c.ActiveWithinDays < 0 {
+		return errors.New("active_within_days must not be negative")
+	}
+	if c.MinAccountAgeDays < 0 || c.MaxAccountAgeDays < 0 {
+		return errors.New("account age bounds must not be negative")
+	}
+	if c.MaxAccountAgeDays > 0 && c.MinAccountAgeDays >= c.MaxAccountAgeDays {
+		return errors.New("min_account_age_days must be below max_account_age_days")
+	}
+	c.VolumeCurrency = Currency(strings.ToUpper(strings.TrimSpace(string(c.VolumeCurrency))))
+	if c.NeedsVolume() {
+		if c.VolumeCurrency == "" {
+			return errors.New("volume_currency is required with volume bounds")
+		}
+		if (c.MinVolume != nil && c.MinVolume.IsNegative()) || (c.MaxVolume != nil && c.MaxVolume.IsNegative()) {
+			return errors.New("volume bounds must not be negative")
+		}
+		if c.MinVolume != nil && c.MaxVolume != nil && c.MinVolume.GreaterThan(*c.MaxVolume) {
+			return errors.New("min_volume must not exceed max_volume")
+		}
+	}
+	return nil
+}
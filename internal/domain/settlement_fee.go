@@ -0,0 +1,14 @@
+package domain
+
+import "github.com/shopspring/decimal"
+
+// NetworkFeeSummary aggregates the on-chain fees paid per network and currency.
+type NetworkFeeSummary struct {
+	Network         BlockchainNetwork `json:"network" db:"network"`
+	Currency        Currency          `json:"currency" db:"currency"`
+	SettlementCount int               `json:"settlement_count" db:"settlement_count"`
+	TotalFees       decimal.Decimal   `json:"total_fees" db:"total_fees"`
+	AverageFee      decimal.Decimal   `json:"average_fee" db:"average_fee"`
+	MaxFee          decimal.Decimal   `json:"max_fee" db:"max_fee"`
+	TotalVolume     decimal.Decimal   `json:"total_volume" db:"total_volume"`
+}
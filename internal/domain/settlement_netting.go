@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// NettedTransaction is one transaction folded into a netting run, as it
+// stood when the window was netted.
+type NettedTransaction struct {
+	TransactionID     uuid.UUID       `json:"transaction_id"`
+	Direction         string          `json:"direction"` // currency pair, e.g. "MWK-ZAR"
+	Amount            decimal.Decimal `json:"amount"`
+	Currency          Currency        `json:"currency"`
+	ExchangeRate      decimal.Decimal `json:"exchange_rate"`
+	ConvertedAmount   decimal.Decimal `json:"converted_amount"`
+	ConvertedCurrency Currency        `json:"converted_currency"`
+}
+
+type NettedTransactions []NettedTransaction
+
+func (n NettedTransactions) Value() (driver.Value, error) {
+	if n == nil {
+		n = NettedTransactions{}
+	}
+	return json.Marshal(n)
+}
+
+func (n *NettedTransactions) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, n)
+}
+
+// SettlementNetting audits one net settlement window of a corridor. Flows
+// in both directions are measured in QuoteCurrency: Outbound is what base
+// senders owe quote receivers, Inbound what quote senders paid in.
+// NetPosition = Outbound - Inbound; a positive position is paid out in the
+// quote currency, a negative one in the base currency, and zero settles
+// nothing on-chain.
+type SettlementNetting struct {
+	ID                 uuid.UUID          `json:"id" db:"id"`
+	SettlementID       uuid.UUID          `json:"settlement_id" db:"settlement_id"`
+	Corridor           string             `json:"corridor" db:"corridor"` // e.g. "MWK-ZAR": base-quote
+	WindowStart        time.Time          `json:"window_start" db:"window_start"`
+	WindowEnd          time.Time          `json:"window_end" db:"window_end"`
+	QuoteCurrency      Currency           `json:"quote_currency" db:"quote_currency"`
+	Outbound           decimal.Decimal    `json:"outbound" db:"outbound"`
+	Inbound            decimal.Decimal    `json:"inbound" db:"inbound"`
+	NetPosition        decimal.Decimal    `json:"net_position" db:"net_position"`
+	SettlementAmount   decimal.Decimal    `json:"settlement_amount" db:"settlement_amount"`
+	SettlementCurrency Currency           `json:"settlement_currency" db:"settlement_currency"`
+	TransactionCount   int                `json:"transaction_count" db:"transaction_count"`
+	Transactions       NettedTransactions `json:"transactions" db:"transactions"`
+	CreatedAt          time.Time          `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// SettlementRoute maps a corridor and amount band to a settlement rail for a
+// period of time. A route with a future EffectiveFrom is a scheduled switchover.
+type SettlementRoute struct {
+	ID            uuid.UUID         `json:"id" db:"id"`
+	Corridor      string            `json:"corridor" db:"corridor"` // e.g. "MWK-CNY", or "*" for any corridor
+	MinAmount     decimal.Decimal   `json:"min_amount" db:"min_amount"`
+	MaxAmount     *decimal.Decimal  `json:"max_amount,omitempty" db:"max_amount"` // exclusive; nil means unbounded
+	Network       BlockchainNetwork `json:"network" db:"network"`
+	EffectiveFrom time.Time         `json:"effective_from" db:"effective_from"`
+	EffectiveTo   *time.Time        `json:"effective_to,omitempty" db:"effective_to"`
+	Notes         *string           `json:"notes,omitempty" db:"notes"`
+	CreatedBy     *uuid.UUID        `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt     time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// RouteSample is a historical transaction used to simulate routing decisions.
+type RouteSample struct {
+	Currency          Currency           `db:"currency"`
+	ConvertedCurrency Currency           `db:"converted_currency"`
+	ConvertedAmount   decimal.Decimal    `db:"converted_amount"`
+	ActualNetwork     *BlockchainNetwork `db:"network"`
+	CreatedAt         time.Time          `db:"created_at"`
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// SpendingControls are limits a customer sets on their own outgoing
+// payments. They can only narrow what the platform already allows; a nil
+// limit means none beyond the platform's.
+type SpendingControls struct {
+	UserID            uuid.UUID        `json:"user_id" db:"user_id"`
+	MaxPerTransaction *decimal.Decimal `json:"max_per_transaction,omitempty" db:"max_per_transaction"`
+	// DailyCap applies to the last 24 hours of payments in each sending
+	// currency, like the platform daily limit.
+	DailyCap         *decimal.Decimal `json:"daily_cap,omitempty" db:"daily_cap"`
+	BlockCrossBorder bool             `json:"block_cross_border" db:"block_cross_border"`
+	BlockedChannels  pq.StringArray   `json:"blocked_channels" db:"blocked_channels"`
+	// Frozen stops all outgoing payments until the customer unfreezes.
+	Frozen    bool      `json:"frozen" db:"frozen"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
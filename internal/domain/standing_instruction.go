@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// StandingInstructionKind is what a standing instruction does with money
+// arriving in its wallet.
+type StandingInstructionKind string
+
+const (
+	// StandingInstructionConvert converts each credit to TargetCurrency,
+	// into the customer's wallet in that currency. Credits smaller than
+	// Threshold are left alone.
+	StandingInstructionConvert StandingInstructionKind = "auto_convert"
+	// StandingInstructionSweep moves the balance above Threshold into the
+	// wallet's pocket PocketID after each credit.
+	StandingInstructionSweep StandingInstructionKind = "auto_sweep"
+)
+
+// StandingInstruction is a customer's rule on money arriving in one of
+// their wallets. A wallet has at most one active instruction. Only credits
+// that settle after ActiveSince are acted on.
+type StandingInstruction struct {
+	ID             uuid.UUID               `json:"id" db:"id"`
+	UserID         uuid.UUID               `json:"user_id" db:"user_id"`
+	WalletID       uuid.UUID               `json:"wallet_id" db:"wallet_id"`
+	Currency       Currency                `json:"currency" db:"currency"`
+	Kind           StandingInstructionKind `json:"kind" db:"kind"`
+	TargetCurrency *Currency               `json:"target_currency,omitempty" db:"target_currency"`
+	PocketID       *uuid.UUID              `json:"pocket_id,omitempty" db:"pocket_id"`
+	Threshold      decimal.Decimal         `json:"threshold" db:"threshold"`
+	Active         bool                    `json:"active" db:"active"`
+	ActiveSince    time.Time               `json:"active_since" db:"active_since"`
+	CreatedAt      time.Time               `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at" db:"updated_at"`
+}
+
+// SavingsPocket is a named part of a wallet's balance set aside from
+// spending. Its money is held in the wallet's reserved balance.
+type SavingsPocket struct {
+	ID        uuid.UUID       `json:"id" db:"id"`
+	UserID    uuid.UUID       `json:"user_id" db:"user_id"`
+	WalletID  uuid.UUID       `json:"wallet_id" db:"wallet_id"`
+	Currency  Currency        `json:"currency" db:"currency"`
+	Name      string          `json:"name" db:"name"`
+	Balance   decimal.Decimal `json:"balance" db:"balance"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// StandingExecutionStatus is what came of acting on one credit.
+type StandingExecutionStatus string
+
+const (
+	// StandingExecutionPending is a credit claimed by a run that has not
+	// finished with it yet.
+	StandingExecutionPending  StandingExecutionStatus = "pending"
+	StandingExecutionExecuted StandingExecutionStatus = "executed"
+	// StandingExecutionSkipped is a credit there was nothing to do for,
+	// such as one below the threshold.
+	StandingExecutionSkipped StandingExecutionStatus = "skipped"
+	StandingExecutionFailed  StandingExecutionStatus = "failed"
+)
+
+// StandingInstructionExecution records an instruction acting on the credit
+// TriggerTransactionID. TransactionID is the conversion it made; a sweep
+// makes none.
+type StandingInstructionExecution struct {
+	ID                   uuid.UUID               `json:"id" db:"id"`
+	InstructionID        uuid.UUID               `json:"instruction_id" db:"instruction_id"`
+	TriggerTransactionID uuid.UUID               `json:"trigger_transaction_id" db:"trigger_transaction_id"`
+	Status               StandingExecutionStatus `json:"status" db:"status"`
+	Amount               decimal.Decimal         `json:"amount" db:"amount"`
+	TransactionID        *uuid.UUID              `json:"transaction_id,omitempty" db:"transaction_id"`
+	Reason               string                  `json:"reason,omitempty" db:"reason"`
+	ClaimedAt            time.Time               `json:"-" db:"claimed_at"`
+	CreatedAt            time.Time               `json:"created_at" db:"created_at"`
+}
+
+// IncomingCredit is settled money in a wallet that an active instruction
+// has yet to act on. Amount is what the wallet received.
+type IncomingCredit struct {
+	InstructionID uuid.UUID       `db:"instruction_id"`
+	TransactionID uuid.UUID       `db:"transaction_id"`
+	Amount        decimal.Decimal `db:"amount"`
+}
+
+// StandingInstructionRun counts what one pass over incoming credits did.
+type StandingInstructionRun struct {
+	Examined int `json:"examined"`
+	Executed int `json:"executed"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// StatementFormat is the file layout of a wallet statement.
+type StatementFormat string
+
+const (
+	// StatementFormatCAMT053 is an ISO 20022 camt.053.001.02 bank to
+	// customer statement, which most ERPs import directly.
+	StatementFormatCAMT053 StatementFormat = "camt053"
+	StatementFormatCSV     StatementFormat = "csv"
+)
+
+// StatementChannel is how statements reach the corporate.
+type StatementChannel string
+
+const (
+	// StatementChannelWebhook posts the statement to the owner's endpoints
+	// subscribed to statement.ready.
+	StatementChannelWebhook StatementChannel = "webhook"
+	// StatementChannelSFTP drops the statement in the outbound folder of
+	// the owner's file channel.
+	StatementChannelSFTP StatementChannel = "sftp"
+)
+
+type StatementDeliveryStatus string
+
+const (
+	StatementDeliveryPending StatementDeliveryStatus = "pending"
+	// StatementDeliverySent is a statement handed to the webhook service,
+	// waiting for an endpoint to accept it.
+	StatementDeliverySent      StatementDeliveryStatus = "sent"
+	StatementDeliveryDelivered StatementDeliveryStatus = "delivered"
+	StatementDeliveryFailed    StatementDeliveryStatus = "failed"
+)
+
+// StatementSchedule sends a statement of one wallet for each UTC day, the
+// day after.
+type StatementSchedule struct {
+	ID       uuid.UUID        `json:"id" db:"id"`
+	OwnerID  uuid.UUID        `json:"owner_id" db:"owner_id"`
+	WalletID uuid.UUID        `json:"wallet_id" db:"wallet_id"`
+	Format   StatementFormat  `json:"format" db:"format"`
+	Channel  StatementChannel `json:"channel" db:"channel"`
+	// LastPeriod is the last UTC day a statement was queued for.
+	LastPeriod *time.Time `json:"last_period,omitempty" db:"last_period"`
+	CreatedBy  uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// StatementDelivery is one day's statement of a wallet on its way to the
+// owner. The file is generated on the first attempt and kept, so retries
+// send the same bytes; regenerating builds a new delivery from the ledger
+// as it is then.
+type StatementDelivery struct {
+	ID         uuid.UUID        `json:"id" db:"id"`
+	ScheduleID *uuid.UUID       `json:"schedule_id,omitempty" db:"schedule_id"`
+	OwnerID    uuid.UUID        `json:"owner_id" db:"owner_id"`
+	WalletID   uuid.UUID        `json:"wallet_id" db:"wallet_id"`
+	Period     time.Time        `json:"period" db:"period"`
+	Format     StatementFormat  `json:"format" db:"format"`
+	Channel    StatementChannel `json:"channel" db:"channel"`
+	// RegeneratedFrom is the delivery this one was regenerated from.
+	RegeneratedFrom *uuid.UUID              `json:"regenerated_from,omitempty" db:"regenerated_from"`
+	Status          StatementDeliveryStatus `json:"status" db:"status"`
+	Attempts        int                     `json:"attempts" db:"attempts"`
+	NextAttemptAt   *time.Time              `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastError       string                  `json:"last_error,omitempty" db:"last_error"`
+	FileName        string                  `json:"file_name,omitempty" db:"file_name"`
+	FileSize        int64                   `json:"file_size" db:"file_size"`
+	SHA256          string                  `json:"sha256,omitempty" db:"sha256"`
+	EntryCount      int                     `json:"entry_count" db:"entry_count"`
+	Content         []byte                  `json:"-" db:"content"`
+	RequestedBy     *uuid.UUID              `json:"requested_by,omitempty" db:"requested_by"`
+	SentAt          *time.Time              `json:"sent_at,omitempty" db:"sent_at"`
+	DeliveredAt     *time.Time              `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt       time.Time               `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time               `json:"updated_at" db:"updated_at"`
+}
+
+// Statement is a wallet's ledger over [From, To): the balance before, each
+// entry booked and the balance after.
+type Statement struct {
+	ID             uuid.UUID
+	WalletID       uuid.UUID
+	WalletNumber   string
+	OwnerName      string
+	Currency       Currency
+	From           time.Time
+	To             time.Time
+	OpeningBalance decimal.Decimal
+	ClosingBalance decimal.Decimal
+	Entries        []StatementEntry
+	CreatedAt      time.Time
+}
+
+// StatementEntry is one ledger entry on a statement.
+type StatementEntry struct {
+	ID            uuid.UUID       `db:"id"`
+	TransactionID uuid.UUID       `db:"transaction_id"`
+	EntryType     string          `db:"entry_type"` // debit or credit
+	Amount        decimal.Decimal `db:"amount"`
+	BalanceAfter  decimal.Decimal `db:"balance_after"`
+	Reference     string          `db:"reference"`
+	Description   string          `db:"description"`
+	BookedAt      time.Time       `db:"created_at"`
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// TransactionCursor marks a position in a transaction listing ordered by
+// creation time. The ID breaks ties between rows created in the same instant.
+type TransactionCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// CursorAfter returns the cursor pointing just past tx.
+func CursorAfter(tx *Transaction) *TransactionCursor {
+	return &TransactionCursor{CreatedAt: tx.CreatedAt, ID: tx.ID}
+}
+
+// Encode returns the opaque form of the cursor handed to API clients.
+func (c TransactionCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseTransactionCursor decodes a cursor produced by Encode.
+func ParseTransactionCursor(s string) (*TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &TransactionCursor{CreatedAt: createdAt, ID: id}, nil
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransactionEventType is a milestone in the lifecycle of a transaction.
+type TransactionEventType string
+
+const (
+	TransactionEventInitiated        TransactionEventType = "initiated"
+	TransactionEventHeldForReview    TransactionEventType = "held_for_review"
+	TransactionEventQueued           TransactionEventType = "queued_for_next_window"
+	TransactionEventCompliancePassed TransactionEventType = "compliance_passed"
+	TransactionEventConverted        TransactionEventType = "converted"
+	TransactionEventSubmitted        TransactionEventType = "submitted_to_network"
+	TransactionEventConfirmed        TransactionEventType = "confirmed"
+	TransactionEventDelivered        TransactionEventType = "delivered"
+	TransactionEventFailed           TransactionEventType = "failed"
+	TransactionEventCancelled        TransactionEventType = "cancelled"
+	TransactionEventReversed         TransactionEventType = "reversed"
+	TransactionEventDisputed         TransactionEventType = "disputed"
+)
+
+// TransactionEvent is an append-only record of a transaction reaching a milestone.
+type TransactionEvent struct {
+	ID            uuid.UUID            `json:"id" db:"id"`
+	TransactionID uuid.UUID            `json:"transaction_id" db:"transaction_id"`
+	Type          TransactionEventType `json:"type" db:"event_type"`
+	Details       Metadata             `json:"details,omitempty" db:"details"`
+	CreatedAt     time.Time            `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// TransactionNoteVisibility says who can read a transaction note.
+type TransactionNoteVisibility string
+
+const (
+	// TransactionNotePrivate is a customer's own annotation: a note and tags
+	// that only its author sees. Each party has at most one per transaction.
+	TransactionNotePrivate TransactionNoteVisibility = "private"
+	// TransactionNoteInternal is written by support or admins and is never
+	// shown to customers. Internal notes are append-only.
+	TransactionNoteInternal TransactionNoteVisibility = "internal"
+)
+
+// TransactionNote is a note attached to a transaction.
+type TransactionNote struct {
+	ID            uuid.UUID                 `json:"id" db:"id"`
+	TransactionID uuid.UUID                 `json:"transaction_id" db:"transaction_id"`
+	AuthorID      uuid.UUID                 `json:"author_id" db:"author_id"`
+	Visibility    TransactionNoteVisibility `json:"visibility" db:"visibility"`
+	Body          string                    `json:"body" db:"body"`
+	Tags          pq.StringArray            `json:"tags" db:"tags"`
+	CreatedAt     time.Time                 `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time                 `json:"updated_at" db:"updated_at"`
+}
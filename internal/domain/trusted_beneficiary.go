@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrustedBeneficiary is a wallet a customer has whitelisted. Payments to it
+// up to a configured amount skip step-up verification once ActiveFrom has
+// passed; the delay gives the customer time to notice a beneficiary added by
+// someone else.
+type TrustedBeneficiary struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	WalletID   uuid.UUID `json:"wallet_id" db:"wallet_id"`
+	Nickname   string    `json:"nickname" db:"nickname"`
+	ActiveFrom time.Time `json:"active_from" db:"active_from"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// IsActive reports whether the cooling-off period has passed at now.
+func (b *TrustedBeneficiary) IsActive(now time.Time) bool {
+	return !now.Before(b.ActiveFrom)
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "github.com/google/uuid"
+
+// DeviceRevocationKey is the token blacklist entry that revokes every access
+// token issued to a sign-in on device id. Access tokens name the device in
+// their "device_id" claim.
+func DeviceRevocationKey(id uuid.UUID) string {
+	return "device:" + id.String()
+}
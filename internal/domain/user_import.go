@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserImportStatus string
+
+const (
+	UserImportQueued     UserImportStatus = "queued"
+	UserImportProcessing UserImportStatus = "processing"
+	UserImportCompleted  UserImportStatus = "completed"
+)
+
+type UserImportRowStatus string
+
+const (
+	// UserImportRowInvalid rows failed validation and are never imported.
+	UserImportRowInvalid UserImportRowStatus = "invalid"
+	UserImportRowPending UserImportRowStatus = "pending"
+	// UserImportRowInvited rows have a provisional account and an
+	// invitation on its way.
+	UserImportRowInvited UserImportRowStatus = "invited"
+	// UserImportRowFailed rows were valid on upload but the account could
+	// not be created, e.g. because the email was registered meanwhile.
+	UserImportRowFailed UserImportRowStatus = "failed"
+)
+
+// UserImport is a CSV of people to onboard as wallet holders, such as a
+// corporate customer's employees. Valid rows become provisional accounts
+// whose holders are invited by email to set a password.
+type UserImport struct {
+	ID           uuid.UUID        `json:"id" db:"id"`
+	FileName     string           `json:"file_name" db:"file_name"`
+	Organization string           `json:"organization,omitempty" db:"organization"`
+	Status       UserImportStatus `json:"status" db:"status"`
+	TotalRows    int              `json:"total_rows" db:"total_rows"`
+	InvalidRows  int              `json:"invalid_rows" db:"invalid_rows"`
+	// ProcessedRows counts valid rows done so far, invited or failed.
+	ProcessedRows int        `json:"processed_rows" db:"processed_rows"`
+	InvitedRows   int        `json:"invited_rows" db:"invited_rows"`
+	FailedRows    int        `json:"failed_rows" db:"failed_rows"`
+	CreatedBy     *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ValidRows is how many rows are imported.
+func (i *UserImport) ValidRows() int {
+	return i.TotalRows - i.InvalidRows
+}
+
+// Progress is the share of valid rows processed, from 0 to 1.
+func (i *UserImport) Progress() float64 {
+	if i.ValidRows() <= 0 {
+		return 1
+	}
+	return float64(i.ProcessedRows) / float64(i.ValidRows())
+}
+
+// UserImportRecord is one person in an import. Beyond who they are, it
+// carries KYC data the organization already holds, which pre-fills their
+// profile and stays pending until verified.
+type UserImportRecord struct {
+	Email       string `json:"email" validate:"required,email"`
+	Phone       string `json:"phone" validate:"required,phone_by_country"`
+	FirstName   string `json:"first_name" validate:"required,max=100"`
+	LastName    string `json:"last_name" validate:"required,max=100"`
+	CountryCode string `json:"country_code" validate:"required,len=2"`
+	DateOfBirth string `json:"date_of_birth,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	City        string `json:"city,omitempty" validate:"max=100"`
+	PostalCode  string `json:"postal_code,omitempty" validate:"max=20"`
+	TaxID       string `json:"tax_id,omitempty" validate:"max=50"`
+}
+
+// UserImportFieldError is why a row failed validation.
+type UserImportFieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// UserImportErrors is stored as JSON.
+type UserImportErrors []UserImportFieldError
+
+func (e UserImportErrors) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(e)
+	return string(b), err
+}
+
+func (e *UserImportErrors) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, e)
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	}
+	return errors.New("unsupported user import errors type")
+}
+
+// UserImportRow is one line of an import and what became of it. Line is the
+// CSV line number, the header being line 1.
+type UserImportRow struct {
+	ImportID    uuid.UUID           `json:"import_id" db:"import_id"`
+	Line        int                 `json:"line" db:"line"`
+	Record      UserImportRecord    `json:"record" db:"-"`
+	Status      UserImportRowStatus `json:"status" db:"status"`
+	Errors      UserImportErrors    `json:"errors,omitempty" db:"errors"`
+	UserID      *uuid.UUID          `json:"user_id,omitempty" db:"user_id"`
+	ProcessedAt *time.Time          `json:"processed_at,omitempty" db:"processed_at"`
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ActivityDirection says whether a transaction took money out of or put
+// money into the wallet whose history it appears in.
+type ActivityDirection string
+
+const (
+	ActivityDebit  ActivityDirection = "debit"
+	ActivityCredit ActivityDirection = "credit"
+)
+
+// WalletActivity is a transaction as it appears in one wallet's history.
+// It is projected from the transaction with the counterparty's name and
+// wallet number already resolved, so history reads need no joins.
+type WalletActivity struct {
+	WalletID      uuid.UUID         `json:"wallet_id" db:"wallet_id"`
+	TransactionID uuid.UUID         `json:"transaction_id" db:"transaction_id"`
+	Direction     ActivityDirection `json:"direction" db:"direction"`
+	// Amount is what moved in or out of this wallet, in its currency.
+	Amount    decimal.Decimal `json:"amount" db:"amount"`
+	Currency  Currency        `json:"currency" db:"currency"`
+	FeeAmount decimal.Decimal `json:"fee_amount" db:"fee_amount"`
+	// The counterparty is unset for top-ups, withdrawals and other
+	// transactions against the wallet alone.
+	CounterpartyID           *uuid.UUID        `json:"counterparty_id,omitempty" db:"counterparty_id"`
+	CounterpartyName         string            `json:"counterparty_name,omitempty" db:"counterparty_name"`
+	CounterpartyWalletNumber string            `json:"counterparty_wallet_number,omitempty" db:"counterparty_wallet_number"`
+	TransactionType          TransactionType   `json:"transaction_type" db:"transaction_type"`
+	Status                   TransactionStatus `json:"status" db:"status"`
+	ReferenceNumber          string            `json:"reference_number,omitempty" db:"reference_number"`
+	Description              string            `json:"description,omitempty" db:"description"`
+	// OccurredAt is when the transaction was created; the feed is ordered
+	// by it.
+	OccurredAt time.Time `json:"occurred_at" db:"occurred_at"`
+	// SourceUpdatedAt is the transaction's UpdatedAt this row reflects.
+	SourceUpdatedAt time.Time `json:"updated_at" db:"source_updated_at"`
+}
+
+// Cursor returns the cursor pointing just past a in its wallet's history.
+func (a *WalletActivity) Cursor() *TransactionCursor {
+	return &TransactionCursor{CreatedAt: a.OccurredAt, ID: a.TransactionID}
+}
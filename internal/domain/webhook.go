@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebhookEventType names an event sent to webhook endpoints.
+type WebhookEventType string
+
+const (
+	WebhookTransactionCreated         WebhookEventType = "transaction.created"
+	WebhookTransactionPendingApproval WebhookEventType = "transaction.pending_approval"
+	WebhookTransactionCompleted       WebhookEventType = "transaction.completed"
+	WebhookTransactionFailed          WebhookEventType = "transaction.failed"
+	WebhookTransactionCancelled       WebhookEventType = "transaction.cancelled"
+	WebhookTransactionReversed        WebhookEventType = "transaction.reversed"
+	WebhookSettlementConfirmed        WebhookEventType = "settlement.confirmed"
+	WebhookReportReady                WebhookEventType = "report.ready"
+	WebhookStatementReady             WebhookEventType = "statement.ready"
+)
+
+// WebhookEventTypes are all events endpoints can subscribe to.
+var WebhookEventTypes = []WebhookEventType{
+	WebhookTransactionCreated,
+	WebhookTransactionPendingApproval,
+	WebhookTransactionCompleted,
+	WebhookTransactionFailed,
+	WebhookTransactionCancelled,
+	WebhookTransactionReversed,
+	WebhookSettlementConfirmed,
+	WebhookReportReady,
+	WebhookStatementReady,
+}
+
+// WebhookEndpoint is a URL that receives signed event callbacks. Endpoints
+// without an owner are the platform's own and receive every event; an
+// owner's endpoints receive events for transactions the owner is a party to.
+type WebhookEndpoint struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	OwnerID     *uuid.UUID `json:"owner_id,omitempty" db:"owner_id"`
+	URL         string     `json:"url" db:"url"`
+	Description string     `json:"description,omitempty" db:"description"`
+	// Events lists the event types sent; empty means all of them.
+	Events pq.StringArray `json:"events" db:"events"`
+	Secret string         `json:"-" db:"secret"`
+	// PreviousSecret still signs deliveries, next to Secret, until
+	// PreviousSecretExpiresAt, so receivers can switch over after a rotation.
+	PreviousSecret          string     `json:"-" db:"previous_secret"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty" db:"previous_secret_expires_at"`
+	Active                  bool       `json:"active" db:"active"`
+	CreatedBy               uuid.UUID  `json:"created_by" db:"created_by"`
+	CreatedAt               time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// SigningSecrets returns the secrets deliveries are signed with at now:
+// the current one, then the previous one while it has not expired.
+func (e *WebhookEndpoint) SigningSecrets(now time.Time) []string {
+	secrets := []string{e.Secret}
+	if e.PreviousSecret != "" && e.PreviousSecretExpiresAt != nil && now.Before(*e.PreviousSecretExpiresAt) {
+		secrets = append(secrets, e.PreviousSecret)
+	}
+	return secrets
+}
+
+// Subscribes reports whether the endpoint wants events of type t.
+func (e *WebhookEndpoint) Subscribes(t WebhookEventType) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, ev := range e.Events {
+		if WebhookEventType(ev) == t {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event on its way to one endpoint, with the outcome
+// of the latest attempt.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id" db:"id"`
+	EndpointID     uuid.UUID             `json:"endpoint_id" db:"endpoint_id"`
+	EventID        uuid.UUID             `json:"event_id" db:"event_id"`
+	EventType      WebhookEventType      `json:"event_type" db:"event_type"`
+	ReplayOf       *uuid.UUID            `json:"replay_of,omitempty" db:"replay_of"` // the original delivery, for replays
+	Payload        JSONPayload           `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	ResponseStatus int                   `json:"response_status,omitempty" db:"response_status"`
+	ResponseBody   string                `json:"response_body,omitempty" db:"response_body"`
+	LastError      string                `json:"last_error,omitempty" db:"last_error"`
+	DurationMS     int64                 `json:"duration_ms,omitempty" db:"duration_ms"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at" db:"updated_at"`
+}
+
+// JSONPayload is a JSON document stored as text and rendered as-is.
+type JSONPayload []byte
+
+func (p JSONPayload) Value() (driver.Value, error) {
+	return string(p), nil
+}
+
+func (p *JSONPayload) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*p = append((*p)[:0], v...)
+	case string:
+		*p = JSONPayload(v)
+	case nil:
+		*p = nil
+	default:
+		return errors.New("unsupported payload type")
+	}
+	return nil
+}
+
+func (p JSONPayload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
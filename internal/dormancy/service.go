@@ -0,0 +1,467 @@
+// Package dormancy runs the dormant account workflow. Customers who have
+// neither logged in nor transacted for the dormancy period are warned as
+// it approaches, then their wallets are frozen. Once the escheatment
+// period has passed, and a final notice has given them time to come back,
+// their balances move to the unclaimed balances ledger account where they
+// are held for the customer or handed to the regulator.
+//
+// A returning dormant customer reactivates the account themselves; one
+// whose balances were escheated asks, and an admin returns the balances
+// and reopens the wallets.
+package dormancy
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrRunInProgress = errors.New("a dormancy run is already in progress")
+	ErrNotDormant    = errors.New("account is not dormant")
+)
+
+// runLimit bounds how many accounts one run advances at each stage.
+const runLimit = 500
+
+// Notification event types.
+const (
+	EventDormancyNotice    = "account_dormancy_notice"
+	EventDormant           = "account_dormant"
+	EventEscheatmentNotice = "account_escheatment_notice"
+	EventEscheated         = "account_escheated"
+	EventReactivated       = "account_reactivated"
+)
+
+type Repository interface {
+	// FindInactive returns customers last active at or before cutoff,
+	// except those already dormant or escheated, oldest first.
+	FindInactive(ctx context.Context, cutoff time.Time, limit int) ([]domain.DormancyCandidate, error)
+	// GetAccount returns nil when the customer never entered the workflow.
+	GetAccount(ctx context.Context, userID uuid.UUID) (*domain.DormantAccount, error)
+	SaveAccount(ctx context.Context, a *domain.DormantAccount) error
+	// FindDormant returns dormant accounts last active at or before cutoff,
+	// oldest first.
+	FindDormant(ctx context.Context, cutoff time.Time, limit int) ([]*domain.DormantAccount, error)
+	ListAccounts(ctx context.Context, status domain.DormancyStatus, limit, offset int) ([]domain.DormantAccount, int, error)
+	// MarkDormant suspends the customer's active wallets, records them in
+	// a.FrozenWallets and saves a, atomically.
+	MarkDormant(ctx context.Context, a *domain.DormantAccount) error
+	// MarkReactivated reopens a.FrozenWallets and saves a, atomically.
+	MarkReactivated(ctx context.Context, a *domain.DormantAccount) error
+	HeldEscheatments(ctx context.Context, userID uuid.UUID) ([]domain.Escheatment, error)
+	EscheatmentReport(ctx context.Context, from, to time.Time) (*domain.EscheatmentReport, error)
+}
+
+// Ledger moves balances to and from unclaimed balances. Satisfied by
+// ledger.Service.
+type Ledger interface {
+	EscheatWallet(ctx context.Context, e *domain.Escheatment) (bool, error)
+	ReturnEscheatment(ctx context.Context, e *domain.Escheatment) (bool, error)
+}
+
+// Notifier tells customers where their account is in the workflow.
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
+// Policy says when accounts go dormant and when their balances are
+// escheated. Both periods count from the customer's last activity.
+type Policy struct {
+	DormantAfterMonths int
+	// NoticeDays are how many days before dormancy warnings go out,
+	// largest first.
+	NoticeDays         []int
+	EscheatAfterMonths int
+	// EscheatAfterByCountry overrides EscheatAfterMonths for the countries
+	// whose regulator sets a different period.
+	EscheatAfterByCountry map[string]int
+	EscheatmentNotice     time.Duration
+}
+
+// PolicyFromConfig builds a policy from service configuration. Malformed
+// notice days and country overrides are ignored.
+func PolicyFromConfig(cfg config.DormancyConfig) Policy {
+	p := Policy{
+		DormantAfterMonths:    cfg.DormantAfterMonths,
+		EscheatAfterMonths:    cfg.EscheatAfterMonths,
+		EscheatAfterByCountry: make(map[string]int),
+		EscheatmentNotice:     time.Duration(cfg.EscheatmentNoticeDays) * 24 * time.Hour,
+	}
+	for _, entry := range cfg.NoticeDays {
+		if days, err := strconv.Atoi(strings.TrimSpace(entry)); err == nil && days > 0 {
+			p.NoticeDays = append(p.NoticeDays, days)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(p.NoticeDays)))
+	for _, entry := range cfg.EscheatAfterByCountry {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		months, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || months <= 0 {
+			continue
+		}
+		p.EscheatAfterByCountry[strings.ToUpper(strings.TrimSpace(parts[0]))] = months
+	}
+	return p
+}
+
+// DormantAt is when a customer last active at lastActivity goes dormant.
+func (p Policy) DormantAt(lastActivity time.Time) time.Time {
+	return lastActivity.AddDate(0, p.DormantAfterMonths, 0)
+}
+
+// EscheatAt is when the balances of a dormant account may be escheated,
+// provided the customer was given notice.
+func (p Policy) EscheatAt(a *domain.DormantAccount) time.Time {
+	months := p.EscheatAfterMonths
+	if m, ok := p.EscheatAfterByCountry[strings.ToUpper(a.CountryCode)]; ok {
+		months = m
+	}
+	return a.LastActivityAt.AddDate(0, months, 0)
+}
+
+// minEscheatMonths is the shortest escheatment period of any country.
+func (p Policy) minEscheatMonths() int {
+	months := p.EscheatAfterMonths
+	for _, m := range p.EscheatAfterByCountry {
+		if m < months {
+			months = m
+		}
+	}
+	return months
+}
+
+// noticesDue is how many warnings should have gone out by now for an
+// account going dormant at dormantAt.
+func (p Policy) noticesDue(now, dormantAt time.Time) int {
+	due := 0
+	for _, days := range p.NoticeDays {
+		if !now.Before(dormantAt.AddDate(0, 0, -days)) {
+			due++
+		}
+	}
+	return due
+}
+
+type Service struct {
+	repo     Repository
+	ledger   Ledger
+	notifier Notifier
+	policy   Policy
+	cfg      config.DormancyConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	running  sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, ledger Ledger, notifier Notifier, cfg config.DormancyConfig, log logger.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		ledger:   ledger,
+		notifier: notifier,
+		policy:   PolicyFromConfig(cfg),
+		cfg:      cfg,
+		logger:   log,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the workflow every configured interval until Stop is called.
+func (s *Service) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := s.Run(context.Background()); err != nil && !errors.Is(err, ErrRunInProgress) {
+					s.logger.Error("Dormancy run failed", map[string]interface{}{"error": err.Error()})
+				}
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Run warns customers approaching dormancy, freezes the wallets of those
+// who reached it and escheats the balances of long-dormant accounts.
+func (s *Service) Run(ctx context.Context) (*domain.DormancyRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer s.running.Unlock()
+
+	now := s.now().UTC()
+	run := &domain.DormancyRun{}
+
+	// Everyone inside the first notice window, or past dormancy.
+	firstNotice := 0
+	if len(s.policy.NoticeDays) > 0 {
+		firstNotice = s.policy.NoticeDays[0]
+	}
+	candidates, err := s.repo.FindInactive(ctx, now.AddDate(0, -s.policy.DormantAfterMonths, firstNotice), runLimit)
+	if err != nil {
+		return nil, err
+	}
+	run.Examined += len(candidates)
+	for _, c := range candidates {
+		if err := s.advance(ctx, c, now, run); err != nil {
+			run.Failed++
+			s.logger.Error("Failed to advance dormant account", map[string]interface{}{"user_id": c.UserID, "error": err.Error()})
+		}
+	}
+
+	noticeDays := int(s.policy.EscheatmentNotice / (24 * time.Hour))
+	dormant, err := s.repo.FindDormant(ctx, now.AddDate(0, -s.policy.minEscheatMonths(), noticeDays), runLimit)
+	if err != nil {
+		return nil, err
+	}
+	run.Examined += len(dormant)
+	for _, a := range dormant {
+		if err := s.escheat(ctx, a, now, run); err != nil {
+			run.Failed++
+			s.logger.Error("Failed to escheat dormant account", map[string]interface{}{"user_id": a.UserID, "error": err.Error()})
+		}
+	}
+	return run, nil
+}
+
+// advance warns an inactive customer or, once the dormancy period has
+// passed, freezes their wallets.
+func (s *Service) advance(ctx context.Context, c domain.DormancyCandidate, now time.Time, run *domain.DormancyRun) error {
+	a, err := s.repo.GetAccount(ctx, c.UserID)
+	if err != nil {
+		return err
+	}
+	if a == nil {
+		a = &domain.DormantAccount{UserID: c.UserID, CreatedAt: now}
+	}
+	if !a.LastActivityAt.Equal(c.LastActivityAt) {
+		// Active since the last warnings; start over.
+		a.LastActivityAt = c.LastActivityAt
+		a.NoticesSent = 0
+		a.DormantAt, a.EscheatmentNoticeAt, a.EscheatedAt = nil, nil, nil
+		a.FrozenWallets = nil
+	}
+	a.CountryCode = c.CountryCode
+	a.UpdatedAt = now
+
+	dormantAt := s.policy.DormantAt(a.LastActivityAt)
+	if !now.Before(dormantAt) {
+		a.Status = domain.DormancyDormant
+		a.DormantAt = &now
+		if err := s.repo.MarkDormant(ctx, a); err != nil {
+			return err
+		}
+		run.Dormant++
+		s.logger.Info("Account went dormant", map[string]interface{}{"user_id": a.UserID, "frozen_wallets": len(a.FrozenWallets)})
+		s.notify(ctx, a.UserID, EventDormant, map[string]interface{}{
+			"last_activity_at": a.LastActivityAt,
+			"escheat_at":       s.policy.EscheatAt(a),
+		})
+		return nil
+	}
+
+	due := s.policy.noticesDue(now, dormantAt)
+	if due <= a.NoticesSent {
+		return nil
+	}
+	a.Status = domain.DormancyNotified
+	a.NoticesSent = due
+	a.LastNoticeAt = &now
+	if err := s.repo.SaveAccount(ctx, a); err != nil {
+		return err
+	}
+	run.Notified++
+	s.notify(ctx, a.UserID, EventDormancyNotice, map[string]interface{}{
+		"last_activity_at": a.LastActivityAt,
+		"dormant_at":       dormantAt,
+	})
+	return nil
+}
+
+// escheat gives a dormant customer final notice or, once the escheatment
+// period and the notice have both run out, escheats their balances.
+func (s *Service) escheat(ctx context.Context, a *domain.DormantAccount, now time.Time, run *domain.DormancyRun) error {
+	escheatAt := s.policy.EscheatAt(a)
+	if a.EscheatmentNoticeAt == nil {
+		if now.Before(escheatAt.Add(-s.policy.EscheatmentNotice)) {
+			return nil
+		}
+		if at := now.Add(s.policy.EscheatmentNotice); at.After(escheatAt) {
+			escheatAt = at
+		}
+		a.EscheatmentNoticeAt = &now
+		a.UpdatedAt = now
+		if err := s.repo.SaveAccount(ctx, a); err != nil {
+			return err
+		}
+		run.EscheatmentNotices++
+		s.notify(ctx, a.UserID, EventEscheatmentNotice, map[string]interface{}{"escheat_at": escheatAt})
+		return nil
+	}
+	if now.Before(escheatAt) || now.Before(a.EscheatmentNoticeAt.Add(s.policy.EscheatmentNotice)) {
+		return nil
+	}
+
+	// A wallet already escheated by an earlier, interrupted run has no
+	// balance left and is skipped.
+	amounts := make(map[domain.Currency]decimal.Decimal)
+	for _, id := range a.FrozenWallets {
+		walletID, err := uuid.Parse(id)
+		if err != nil {
+			return err
+		}
+		e := &domain.Escheatment{ID: uuid.New(), UserID: a.UserID, WalletID: walletID, CountryCode: a.CountryCode}
+		moved, err := s.ledger.EscheatWallet(ctx, e)
+		if err != nil {
+			return err
+		}
+		if moved {
+			amounts[e.Currency] = amounts[e.Currency].Add(e.Amount)
+		}
+	}
+	a.Status = domain.DormancyEscheated
+	a.EscheatedAt = &now
+	a.UpdatedAt = now
+	if err := s.repo.SaveAccount(ctx, a); err != nil {
+		return err
+	}
+	run.Escheated++
+	s.logger.Info("Dormant balances escheated", map[string]interface{}{"user_id": a.UserID, "amounts": amounts})
+	s.notify(ctx, a.UserID, EventEscheated, map[string]interface{}{"amounts": amounts})
+	return nil
+}
+
+// Status returns where the customer is in the workflow, or nil when they
+// never entered it.
+func (s *Service) Status(ctx context.Context, userID uuid.UUID) (*domain.DormantAccount, []domain.Escheatment, error) {
+	a, err := s.repo.GetAccount(ctx, userID)
+	if err != nil || a == nil {
+		return nil, nil, err
+	}
+	held, err := s.repo.HeldEscheatments(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, held, nil
+}
+
+// RequestReactivation is a returning customer asking for their account
+// back. A dormant account is reopened at once; an escheated one waits for
+// an admin to return the balances.
+func (s *Service) RequestReactivation(ctx context.Context, userID uuid.UUID) (*domain.DormantAccount, error) {
+	a, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrNotDormant
+	}
+	switch a.Status {
+	case domain.DormancyDormant:
+		return a, s.reactivate(ctx, a, nil)
+	case domain.DormancyEscheated:
+		a.Status = domain.DormancyReactivationRequested
+		a.UpdatedAt = s.now().UTC()
+		if err := s.repo.SaveAccount(ctx, a); err != nil {
+			return nil, err
+		}
+		s.logger.Info("Reactivation of escheated account requested", map[string]interface{}{"user_id": userID})
+		return a, nil
+	case domain.DormancyReactivationRequested:
+		return a, nil
+	default:
+		return nil, ErrNotDormant
+	}
+}
+
+// Reactivate is an admin returning a customer's escheated balances and
+// reopening their wallets.
+func (s *Service) Reactivate(ctx context.Context, userID, adminID uuid.UUID) (*domain.DormantAccount, error) {
+	a, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrNotDormant
+	}
+	switch a.Status {
+	case domain.DormancyDormant, domain.DormancyEscheated, domain.DormancyReactivationRequested:
+	default:
+		return nil, ErrNotDormant
+	}
+
+	held, err := s.repo.HeldEscheatments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range held {
+		if _, err := s.ledger.ReturnEscheatment(ctx, &held[i]); err != nil {
+			return nil, err
+		}
+	}
+	return a, s.reactivate(ctx, a, &adminID)
+}
+
+func (s *Service) reactivate(ctx context.Context, a *domain.DormantAccount, by *uuid.UUID) error {
+	now := s.now().UTC()
+	a.Status = domain.DormancyReactivated
+	a.ReactivatedAt = &now
+	a.ReactivatedBy = by
+	a.UpdatedAt = now
+	if err := s.repo.MarkReactivated(ctx, a); err != nil {
+		return err
+	}
+	s.logger.Info("Dormant account reactivated", map[string]interface{}{"user_id": a.UserID, "by": by})
+	s.notify(ctx, a.UserID, EventReactivated, nil)
+	return nil
+}
+
+// ListAccounts pages through the accounts in the workflow, optionally in
+// one status.
+func (s *Service) ListAccounts(ctx context.Context, status domain.DormancyStatus, limit, offset int) ([]domain.DormantAccount, int, error) {
+	return s.repo.ListAccounts(ctx, status, limit, offset)
+}
+
+// Report totals the escheatments made in [from, to) per country and
+// currency, for filing with the regulator.
+func (s *Service) Report(ctx context.Context, from, to time.Time) (*domain.EscheatmentReport, error) {
+	return s.repo.EscheatmentReport(ctx, from, to)
+}
+
+// notify is best effort: a missed notice must not hold up the workflow.
+func (s *Service) notify(ctx context.Context, userID uuid.UUID, event string, data map[string]interface{}) {
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.Notify(ctx, userID, event, data); err != nil {
+		s.logger.Warn("Failed to send dormancy notification", map[string]interface{}{"user_id": userID, "event": event, "error": err.Error()})
+	}
+}
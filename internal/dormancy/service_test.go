@@ -0,0 +1,462 @@
+package dormancy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) FindInactive(ctx context.Context, cutoff time.Time, limit int) ([]domain.DormancyCandidate, error) {
+	args := m.Called(ctx, cutoff, limit)
+	return args.Get(0).([]domain.DormancyCandidate), args.Error(1)
+}
+
+func (m *MockRepository) GetAccount(ctx context.Context, userID uuid.UUID) (*domain.DormantAccount, error) {
+	args := m.Called(ctx, userID)
+	a, _ := args.Get(0).(*domain.DormantAccount)
+	if a == nil {
+		return nil, args.Error(1)
+	}
+	copied := *a
+	return &copied, args.Error(1)
+}
+
+func (m *MockRepository) SaveAccount(ctx context.Context, a *domain.DormantAccount) error {
+	return m.Called(ctx, a).Error(0)
+}
+
+func (m *MockRepository) FindDormant(ctx context.Context, cutoff time.Time, limit int) ([]*domain.DormantAccount, error) {
+	args := m.Called(ctx, cutoff, limit)
+	return args.Get(0).([]*domain.DormantAccount), args.Error(1)
+}
+
+func (m *MockRepository) ListAccounts(ctx context.Context, status domain.DormancyStatus, limit, offset int) ([]domain.DormantAccount, int, error) {
+	args := m.Called(ctx, status, limit, offset)
+	return args.Get(0).([]domain.DormantAccount), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) MarkDormant(ctx context.Context, a *domain.DormantAccount) error {
+	args := m.Called(ctx, a)
+	if frozen, ok := args.Get(1).(pq.StringArray); ok {
+		a.FrozenWallets = frozen
+	}
+	return args.Error(0)
+}
+
+func (m *MockRepository) MarkReactivated(ctx context.Context, a *domain.DormantAccount) error {
+	return m.Called(ctx, a).Error(0)
+}
+
+func (m *MockRepository) HeldEscheatments(ctx context.Context, userID uuid.UUID) ([]domain.Escheatment, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.Escheatment), args.Error(1)
+}
+
+func (m *MockRepository) EscheatmentReport(ctx context.Context, from, to time.Time) (*domain.EscheatmentReport, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).(*domain.EscheatmentReport), args.Error(1)
+}
+
+type MockLedger struct {
+	mock.Mock
+}
+
+func (m *MockLedger) EscheatWallet(ctx context.Context, e *domain.Escheatment) (bool, error) {
+	args := m.Called(ctx, e)
+	if args.Bool(0) {
+		e.Amount, e.Currency = args.Get(2).(decimal.Decimal), "MWK"
+	}
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLedger) ReturnEscheatment(ctx context.Context, e *domain.Escheatment) (bool, error) {
+	args := m.Called(ctx, e)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
+	return m.Called(ctx, userID, eventType, data).Error(0)
+}
+
+var (
+	ctx        = context.Background()
+	lastActive = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newService(repo *MockRepository, ledger *MockLedger, notifier *MockNotifier, now *time.Time) *Service {
+	s := NewService(repo, ledger, notifier, config.DormancyConfig{
+		DormantAfterMonths:    12,
+		NoticeDays:            []string{"7", "30", "bogus"},
+		EscheatAfterMonths:    60,
+		EscheatAfterByCountry: []string{"mw=24", "ZA", "KE=x"},
+		EscheatmentNoticeDays: 30,
+	}, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+// account matches a saved account by status and the number of notices
+// sent.
+func account(status domain.DormancyStatus, notices int) interface{} {
+	return mock.MatchedBy(func(a *domain.DormantAccount) bool {
+		return a.Status == status && a.NoticesSent == notices
+	})
+}
+
+func TestPolicyFromConfig(t *testing.T) {
+	now := lastActive
+	p := newService(nil, nil, nil, &now).policy
+	assert.Equal(t, []int{30, 7}, p.NoticeDays)
+	assert.Equal(t, map[string]int{"MW": 24}, p.EscheatAfterByCountry)
+	assert.Equal(t, 24, p.minEscheatMonths())
+}
+
+// expectRun has a run find candidates as inactive and dormant as
+// dormant.
+func expectRun(repo *MockRepository, candidates []domain.DormancyCandidate, dormant ...*domain.DormantAccount) {
+	repo.On("FindInactive", ctx, mock.Anything, runLimit).Return(candidates, nil).Once()
+	repo.On("FindDormant", ctx, mock.Anything, runLimit).Return(dormant, nil).Once()
+}
+
+func TestRunSearchesFromTheFirstNotice(t *testing.T) {
+	repo := new(MockRepository)
+	now := lastActive
+	s := newService(repo, new(MockLedger), new(MockNotifier), &now)
+	// Inside the 30 day notice window; Malawi's 24 months less its notice.
+	repo.On("FindInactive", ctx, now.AddDate(0, -12, 30), runLimit).Return([]domain.DormancyCandidate{}, nil).Once()
+	repo.On("FindDormant", ctx, now.AddDate(0, -24, 30), runLimit).Return([]*domain.DormantAccount{}, nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, *run)
+	repo.AssertExpectations(t)
+}
+
+func TestRunSendsDormancyNotices(t *testing.T) {
+	user := uuid.New()
+	candidate := []domain.DormancyCandidate{{UserID: user, CountryCode: "MW", LastActivityAt: lastActive}}
+	notified := func(n int) *domain.DormantAccount {
+		return &domain.DormantAccount{UserID: user, Status: domain.DormancyNotified, LastActivityAt: lastActive, NoticesSent: n}
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		account *domain.DormantAccount
+		notices int
+	}{
+		{"too early", lastActive.AddDate(0, 12, -31), nil, 0},
+		{"first notice", lastActive.AddDate(0, 12, -30), nil, 1},
+		{"first notice already sent", lastActive.AddDate(0, 12, -20), notified(1), 0},
+		{"second notice", lastActive.AddDate(0, 12, -7), notified(1), 2},
+		{"second notice already sent", lastActive.AddDate(0, 12, -1), notified(2), 0},
+		{"active since the notices", lastActive.AddDate(0, 12, -30), &domain.DormantAccount{
+			UserID: user, Status: domain.DormancyNotified, LastActivityAt: lastActive.AddDate(0, -1, 0), NoticesSent: 2,
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, notifier := new(MockRepository), new(MockNotifier)
+			now := tt.now
+			s := newService(repo, new(MockLedger), notifier, &now)
+			expectRun(repo, candidate)
+			repo.On("GetAccount", ctx, user).Return(tt.account, nil).Once()
+			if tt.notices > 0 {
+				repo.On("SaveAccount", ctx, account(domain.DormancyNotified, tt.notices)).Return(nil).Once()
+				notifier.On("Notify", ctx, user, EventDormancyNotice, mock.Anything).Return(nil).Once()
+			}
+
+			run, err := s.Run(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, min(tt.notices, 1), run.Notified)
+			repo.AssertExpectations(t)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunMarksDormant(t *testing.T) {
+	repo, notifier := new(MockRepository), new(MockNotifier)
+	now := lastActive.AddDate(0, 13, 0)
+	s := newService(repo, new(MockLedger), notifier, &now)
+	user, wallet := uuid.New(), uuid.New()
+	expectRun(repo, []domain.DormancyCandidate{{UserID: user, CountryCode: "MW", LastActivityAt: lastActive}})
+	// Missed both notices: dormant at once, without a late warning.
+	repo.On("GetAccount", ctx, user).Return(nil, nil).Once()
+	repo.On("MarkDormant", ctx, mock.MatchedBy(func(a *domain.DormantAccount) bool {
+		return a.Status == domain.DormancyDormant && a.DormantAt.Equal(now) && a.CountryCode == "MW"
+	})).Return(nil, pq.StringArray{wallet.String()}).Once()
+	notifier.On("Notify", ctx, user, EventDormant, map[string]interface{}{
+		"last_activity_at": lastActive,
+		"escheat_at":       lastActive.AddDate(0, 24, 0),
+	}).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DormancyRun{Examined: 1, Dormant: 1}, *run)
+	repo.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRunCountsFailures(t *testing.T) {
+	repo, notifier := new(MockRepository), new(MockNotifier)
+	now := lastActive.AddDate(0, 13, 0)
+	s := newService(repo, new(MockLedger), notifier, &now)
+	failing, ok := uuid.New(), uuid.New()
+	expectRun(repo, []domain.DormancyCandidate{
+		{UserID: failing, LastActivityAt: lastActive},
+		{UserID: ok, LastActivityAt: lastActive},
+	})
+	repo.On("GetAccount", ctx, failing).Return(nil, errors.New("db down")).Once()
+	repo.On("GetAccount", ctx, ok).Return(nil, nil).Once()
+	repo.On("MarkDormant", ctx, account(domain.DormancyDormant, 0)).Return(nil, nil).Once()
+	// A failed notification does not hold up the workflow.
+	notifier.On("Notify", ctx, ok, EventDormant, mock.Anything).Return(errors.New("smtp down")).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DormancyRun{Examined: 2, Dormant: 1, Failed: 1}, *run)
+	repo.AssertExpectations(t)
+}
+
+func TestRunEscheats(t *testing.T) {
+	user, funded, empty := uuid.New(), uuid.New(), uuid.New()
+	dormant := func(noticeAt *time.Time) *domain.DormantAccount {
+		return &domain.DormantAccount{
+			UserID: user, CountryCode: "MW", Status: domain.DormancyDormant, LastActivityAt: lastActive,
+			FrozenWallets: pq.StringArray{funded.String(), empty.String()}, EscheatmentNoticeAt: noticeAt,
+		}
+	}
+	escheatAt := lastActive.AddDate(0, 24, 0)
+	noticeAt := escheatAt.AddDate(0, 0, -30)
+
+	t.Run("too early for notice", func(t *testing.T) {
+		repo := new(MockRepository)
+		now := noticeAt.Add(-time.Hour)
+		s := newService(repo, new(MockLedger), new(MockNotifier), &now)
+		expectRun(repo, nil, dormant(nil))
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DormancyRun{Examined: 1}, *run)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("final notice", func(t *testing.T) {
+		repo, notifier := new(MockRepository), new(MockNotifier)
+		now := noticeAt
+		s := newService(repo, new(MockLedger), notifier, &now)
+		expectRun(repo, nil, dormant(nil))
+		repo.On("SaveAccount", ctx, mock.MatchedBy(func(a *domain.DormantAccount) bool {
+			return a.Status == domain.DormancyDormant && a.EscheatmentNoticeAt.Equal(now)
+		})).Return(nil).Once()
+		notifier.On("Notify", ctx, user, EventEscheatmentNotice, map[string]interface{}{"escheat_at": escheatAt}).Return(nil).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.EscheatmentNotices)
+		repo.AssertExpectations(t)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("late notice moves escheatment back", func(t *testing.T) {
+		repo, notifier := new(MockRepository), new(MockNotifier)
+		now := escheatAt.AddDate(0, 0, 1)
+		s := newService(repo, new(MockLedger), notifier, &now)
+		expectRun(repo, nil, dormant(nil))
+		repo.On("SaveAccount", ctx, mock.Anything).Return(nil).Once()
+		notifier.On("Notify", ctx, user, EventEscheatmentNotice, map[string]interface{}{"escheat_at": now.AddDate(0, 0, 30)}).Return(nil).Once()
+
+		_, err := s.Run(ctx)
+		require.NoError(t, err)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("notice not yet run out", func(t *testing.T) {
+		repo := new(MockRepository)
+		sent := escheatAt.AddDate(0, 0, -10)
+		now := escheatAt
+		s := newService(repo, new(MockLedger), new(MockNotifier), &now)
+		expectRun(repo, nil, dormant(&sent))
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, run.Escheated)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("escheated", func(t *testing.T) {
+		repo, ledger, notifier := new(MockRepository), new(MockLedger), new(MockNotifier)
+		now := escheatAt
+		s := newService(repo, ledger, notifier, &now)
+		expectRun(repo, nil, dormant(&noticeAt))
+		wallet := func(id uuid.UUID) interface{} {
+			return mock.MatchedBy(func(e *domain.Escheatment) bool {
+				return e.WalletID == id && e.UserID == user && e.CountryCode == "MW"
+			})
+		}
+		ledger.On("EscheatWallet", ctx, wallet(funded)).Return(true, nil, decimal.NewFromInt(1500)).Once()
+		// Already escheated by an interrupted run.
+		ledger.On("EscheatWallet", ctx, wallet(empty)).Return(false, nil).Once()
+		repo.On("SaveAccount", ctx, mock.MatchedBy(func(a *domain.DormantAccount) bool {
+			return a.Status == domain.DormancyEscheated && a.EscheatedAt.Equal(now)
+		})).Return(nil).Once()
+		notifier.On("Notify", ctx, user, EventEscheated, mock.MatchedBy(func(data map[string]interface{}) bool {
+			amounts := data["amounts"].(map[domain.Currency]decimal.Decimal)
+			return len(amounts) == 1 && amounts["MWK"].Equal(decimal.NewFromInt(1500))
+		})).Return(nil).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DormancyRun{Examined: 1, Escheated: 1}, *run)
+		repo.AssertExpectations(t)
+		ledger.AssertExpectations(t)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("ledger failure leaves the account dormant", func(t *testing.T) {
+		repo, ledger := new(MockRepository), new(MockLedger)
+		now := escheatAt
+		s := newService(repo, ledger, new(MockNotifier), &now)
+		expectRun(repo, nil, dormant(&noticeAt))
+		ledger.On("EscheatWallet", ctx, mock.Anything).Return(false, errors.New("ledger down")).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DormancyRun{Examined: 1, Failed: 1}, *run)
+		repo.AssertNotCalled(t, "SaveAccount", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRequestReactivation(t *testing.T) {
+	user := uuid.New()
+	now := lastActive.AddDate(2, 0, 0)
+	in := func(status domain.DormancyStatus) *domain.DormantAccount {
+		return &domain.DormantAccount{UserID: user, Status: status, LastActivityAt: lastActive}
+	}
+
+	t.Run("dormant reopens at once", func(t *testing.T) {
+		repo, notifier := new(MockRepository), new(MockNotifier)
+		s := newService(repo, new(MockLedger), notifier, &now)
+		repo.On("GetAccount", ctx, user).Return(in(domain.DormancyDormant), nil).Once()
+		repo.On("MarkReactivated", ctx, mock.MatchedBy(func(a *domain.DormantAccount) bool {
+			return a.Status == domain.DormancyReactivated && a.ReactivatedAt.Equal(now) && a.ReactivatedBy == nil
+		})).Return(nil).Once()
+		notifier.On("Notify", ctx, user, EventReactivated, mock.Anything).Return(nil).Once()
+
+		a, err := s.RequestReactivation(ctx, user)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DormancyReactivated, a.Status)
+		repo.AssertExpectations(t)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("escheated waits for an admin", func(t *testing.T) {
+		repo := new(MockRepository)
+		s := newService(repo, new(MockLedger), new(MockNotifier), &now)
+		repo.On("GetAccount", ctx, user).Return(in(domain.DormancyEscheated), nil).Once()
+		repo.On("SaveAccount", ctx, account(domain.DormancyReactivationRequested, 0)).Return(nil).Once()
+
+		a, err := s.RequestReactivation(ctx, user)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DormancyReactivationRequested, a.Status)
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "MarkReactivated", mock.Anything, mock.Anything)
+	})
+
+	t.Run("already requested", func(t *testing.T) {
+		repo := new(MockRepository)
+		s := newService(repo, new(MockLedger), new(MockNotifier), &now)
+		repo.On("GetAccount", ctx, user).Return(in(domain.DormancyReactivationRequested), nil).Once()
+
+		a, err := s.RequestReactivation(ctx, user)
+		require.NoError(t, err)
+		assert.Equal(t, domain.DormancyReactivationRequested, a.Status)
+		repo.AssertNotCalled(t, "SaveAccount", mock.Anything, mock.Anything)
+	})
+
+	for _, a := range []*domain.DormantAccount{nil, in(domain.DormancyNotified), in(domain.DormancyReactivated)} {
+		repo := new(MockRepository)
+		s := newService(repo, new(MockLedger), new(MockNotifier), &now)
+		repo.On("GetAccount", ctx, user).Return(a, nil).Once()
+		_, err := s.RequestReactivation(ctx, user)
+		assert.ErrorIs(t, err, ErrNotDormant)
+	}
+}
+
+func TestReactivate(t *testing.T) {
+	user, admin := uuid.New(), uuid.New()
+	now := lastActive.AddDate(3, 0, 0)
+	held := []domain.Escheatment{{ID: uuid.New(), UserID: user}, {ID: uuid.New(), UserID: user}}
+
+	repo, ledger, notifier := new(MockRepository), new(MockLedger), new(MockNotifier)
+	s := newService(repo, ledger, notifier, &now)
+	repo.On("GetAccount", ctx, user).Return(&domain.DormantAccount{
+		UserID: user, Status: domain.DormancyReactivationRequested, LastActivityAt: lastActive,
+	}, nil).Once()
+	repo.On("HeldEscheatments", ctx, user).Return(held, nil).Once()
+	for _, e := range held {
+		id := e.ID
+		ledger.On("ReturnEscheatment", ctx, mock.MatchedBy(func(e *domain.Escheatment) bool { return e.ID == id })).Return(true, nil).Once()
+	}
+	repo.On("MarkReactivated", ctx, mock.MatchedBy(func(a *domain.DormantAccount) bool {
+		return a.Status == domain.DormancyReactivated && *a.ReactivatedBy == admin
+	})).Return(nil).Once()
+	notifier.On("Notify", ctx, user, EventReactivated, mock.Anything).Return(nil).Once()
+
+	a, err := s.Reactivate(ctx, user, admin)
+	require.NoError(t, err)
+	assert.Equal(t, &admin, a.ReactivatedBy)
+	repo.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+
+	t.Run("balances not returned", func(t *testing.T) {
+		repo, ledger := new(MockRepository), new(MockLedger)
+		s := newService(repo, ledger, new(MockNotifier), &now)
+		repo.On("GetAccount", ctx, user).Return(&domain.DormantAccount{UserID: user, Status: domain.DormancyEscheated}, nil).Once()
+		repo.On("HeldEscheatments", ctx, user).Return(held, nil).Once()
+		ledger.On("ReturnEscheatment", ctx, mock.Anything).Return(false, errors.New("ledger down")).Once()
+
+		_, err := s.Reactivate(ctx, user, admin)
+		assert.Error(t, err)
+		repo.AssertNotCalled(t, "MarkReactivated", mock.Anything, mock.Anything)
+	})
+
+	t.Run("not dormant", func(t *testing.T) {
+		repo := new(MockRepository)
+		s := newService(repo, new(MockLedger), new(MockNotifier), &now)
+		repo.On("GetAccount", ctx, user).Return(&domain.DormantAccount{UserID: user, Status: domain.DormancyReactivated}, nil).Once()
+
+		_, err := s.Reactivate(ctx, user, admin)
+		assert.ErrorIs(t, err, ErrNotDormant)
+	})
+}
+
+func TestRunRejectsConcurrentRun(t *testing.T) {
+	now := lastActive
+	s := newService(new(MockRepository), new(MockLedger), new(MockNotifier), &now)
+	s.running.Lock()
+	defer s.running.Unlock()
+	_, err := s.Run(ctx)
+	assert.ErrorIs(t, err, ErrRunInProgress)
+}
@@ -0,0 +1,219 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("report schedule not found")
+	ErrScheduleExists   = errors.New("a schedule for this report already exists")
+
+	errSchedulesDisabled = errors.New("report schedules are not configured")
+)
+
+// maxReportWindow is the longest period one partner report may cover.
+const maxReportWindow = 31 * 24 * time.Hour
+
+// ScheduleRepository stores daily report schedules.
+type ScheduleRepository interface {
+	// CreateReportSchedule returns ErrScheduleExists when the owner already
+	// has a schedule for the same kind and currency.
+	CreateReportSchedule(ctx context.Context, sch *domain.ReportSchedule) error
+	// ListReportSchedules returns the owner's schedules, or all of them when
+	// owner is nil.
+	ListReportSchedules(ctx context.Context, owner *uuid.UUID) ([]domain.ReportSchedule, error)
+	DeleteReportSchedule(ctx context.Context, id uuid.UUID, owner *uuid.UUID) (bool, error)
+	// ListDueReportSchedules returns up to limit schedules that have not
+	// yet queued a report for period.
+	ListDueReportSchedules(ctx context.Context, period time.Time, limit int) ([]domain.ReportSchedule, error)
+	// QueueScheduledReport records period as the schedule's last and creates
+	// j in one transaction. It reports false, creating nothing, when the
+	// period was already queued, so each day is reported once however many
+	// workers run.
+	QueueScheduledReport(ctx context.Context, scheduleID uuid.UUID, period time.Time, j *domain.ExportJob) (bool, error)
+}
+
+// Notifier tells a partner that one of their reports is ready.
+type Notifier interface {
+	PublishReport(ctx context.Context, j *domain.ExportJob, downloadURL string, expiresAt time.Time) error
+}
+
+// WithSchedules enables daily partner report schedules.
+func (s *Service) WithSchedules(repo ScheduleRepository) *Service {
+	s.schedules = repo
+	return s
+}
+
+// WithNotifier pushes a signed download link to the owner of each report
+// that completes.
+func (s *Service) WithNotifier(n Notifier) *Service {
+	s.notifier = n
+	return s
+}
+
+// ReportRequest asks for a partner report over [From, To), optionally in
+// one currency.
+type ReportRequest struct {
+	Kind     domain.ExportKind `json:"kind"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Currency string            `json:"currency"`
+}
+
+// SubmitReport queues a report of the owner's own transactions.
+func (s *Service) SubmitReport(ctx context.Context, owner uuid.UUID, req ReportRequest, requestedBy uuid.UUID) (*domain.ExportJob, error) {
+	if !req.Kind.IsReport() {
+		return nil, ErrUnknownKind
+	}
+	if req.From.IsZero() || req.To.IsZero() || !req.From.Before(req.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidFilters)
+	}
+	if req.To.Sub(req.From) > maxReportWindow {
+		return nil, fmt.Errorf("%w: a report covers at most 31 days", ErrInvalidFilters)
+	}
+	from, to := req.From.UTC(), req.To.UTC()
+	j, err := s.newJob(req.Kind, domain.ExportFilters{
+		From:     &from,
+		To:       &to,
+		Currency: strings.ToUpper(strings.TrimSpace(req.Currency)),
+	}, requestedBy, &owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateExportJob(ctx, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// GetReport returns one of the owner's reports.
+func (s *Service) GetReport(ctx context.Context, owner, id uuid.UUID) (*domain.ExportJob, error) {
+	j, err := s.repo.GetExportJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if j.OwnerID == nil || *j.OwnerID != owner {
+		return nil, ErrNotFound
+	}
+	return j, nil
+}
+
+// ListReports returns the owner's reports, newest first.
+func (s *Service) ListReports(ctx context.Context, owner uuid.UUID, limit, offset int) ([]domain.ExportJob, int, error) {
+	return s.repo.ListExportJobs(ctx, &owner, limit, offset)
+}
+
+// ScheduleRequest sets up a daily report.
+type ScheduleRequest struct {
+	Kind     domain.ExportKind `json:"kind"`
+	Currency string            `json:"currency"`
+}
+
+// CreateSchedule generates the report for owner every day from now on,
+// starting with yesterday's.
+func (s *Service) CreateSchedule(ctx context.Context, owner uuid.UUID, req ScheduleRequest, createdBy uuid.UUID) (*domain.ReportSchedule, error) {
+	if s.schedules == nil {
+		return nil, errSchedulesDisabled
+	}
+	if !req.Kind.IsReport() {
+		return nil, ErrUnknownKind
+	}
+	sch := &domain.ReportSchedule{
+		ID:        uuid.New(),
+		OwnerID:   owner,
+		Kind:      req.Kind,
+		Currency:  strings.ToUpper(strings.TrimSpace(req.Currency)),
+		CreatedBy: createdBy,
+		CreatedAt: s.now().UTC(),
+	}
+	if err := s.schedules.CreateReportSchedule(ctx, sch); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// ListSchedules returns owner's schedules, or all of them when owner is nil.
+func (s *Service) ListSchedules(ctx context.Context, owner *uuid.UUID) ([]domain.ReportSchedule, error) {
+	if s.schedules == nil {
+		return []domain.ReportSchedule{}, nil
+	}
+	return s.schedules.ListReportSchedules(ctx, owner)
+}
+
+// DeleteSchedule stops a schedule. owner limits it to that owner's
+// schedules; nil allows any (admin).
+func (s *Service) DeleteSchedule(ctx context.Context, owner *uuid.UUID, id uuid.UUID) error {
+	if s.schedules == nil {
+		return errSchedulesDisabled
+	}
+	deleted, err := s.schedules.DeleteReportSchedule(ctx, id, owner)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// reportPeriod is the UTC day whose scheduled reports are due at now.
+func (s *Service) reportPeriod(now time.Time) time.Time {
+	return now.UTC().Add(-s.cfg.ReportDelay).Truncate(24*time.Hour).AddDate(0, 0, -1)
+}
+
+// QueueScheduledReports queues the reports of every schedule that has not
+// yet had one for the latest finished day, and returns how many it queued.
+func (s *Service) QueueScheduledReports(ctx context.Context) int {
+	if s.schedules == nil {
+		return 0
+	}
+	period := s.reportPeriod(s.now())
+	due, err := s.schedules.ListDueReportSchedules(ctx, period, 100)
+	if err != nil {
+		s.logger.Error("Failed to list due report schedules", map[string]interface{}{"error": err.Error()})
+		return 0
+	}
+	queued := 0
+	for _, sch := range due {
+		from, to := period, period.AddDate(0, 0, 1)
+		owner := sch.OwnerID
+		j, err := s.newJob(sch.Kind, domain.ExportFilters{From: &from, To: &to, Currency: sch.Currency}, sch.CreatedBy, &owner)
+		if err == nil {
+			var ok bool
+			if ok, err = s.schedules.QueueScheduledReport(ctx, sch.ID, period, j); ok {
+				queued++
+			}
+		}
+		if err != nil {
+			s.logger.Error("Failed to queue scheduled report", map[string]interface{}{
+				"error":       err.Error(),
+				"schedule_id": sch.ID,
+				"period":      period.Format("2006-01-02"),
+			})
+		}
+	}
+	return queued
+}
+
+// notifyReady pushes a link to a completed report to its owner. The link
+// lasts as long as the file, since the partner may fetch it much later.
+func (s *Service) notifyReady(ctx context.Context, j *domain.ExportJob) {
+	if s.notifier == nil || j.OwnerID == nil {
+		return
+	}
+	path, expires, err := s.downloadURL(j, s.cfg.Retention)
+	if err == nil {
+		err = s.notifier.PublishReport(ctx, j, s.cfg.PublicURL+path, expires)
+	}
+	if err != nil {
+		s.logger.Error("Failed to notify report owner", map[string]interface{}{"error": err.Error(), "job_id": j.ID})
+	}
+}
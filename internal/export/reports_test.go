@@ -0,0 +1,211 @@
+package export
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSource remembers the filters it was asked for.
+type recordingSource struct {
+	sliceSource
+	filters []domain.ExportFilters
+}
+
+func (s *recordingSource) Count(ctx context.Context, f domain.ExportFilters) (int, error) {
+	s.filters = append(s.filters, f)
+	return s.sliceSource.Count(ctx, f)
+}
+
+type memorySchedules struct {
+	repo      *memoryRepository
+	schedules map[uuid.UUID]*domain.ReportSchedule
+}
+
+func (m *memorySchedules) CreateReportSchedule(_ context.Context, sch *domain.ReportSchedule) error {
+	for _, existing := range m.schedules {
+		if existing.OwnerID == sch.OwnerID && existing.Kind == sch.Kind && existing.Currency == sch.Currency {
+			return ErrScheduleExists
+		}
+	}
+	m.schedules[sch.ID] = sch
+	return nil
+}
+
+func (m *memorySchedules) ListReportSchedules(_ context.Context, owner *uuid.UUID) ([]domain.ReportSchedule, error) {
+	var out []domain.ReportSchedule
+	for _, sch := range m.schedules {
+		if owner == nil || sch.OwnerID == *owner {
+			out = append(out, *sch)
+		}
+	}
+	return out, nil
+}
+
+func (m *memorySchedules) DeleteReportSchedule(_ context.Context, id uuid.UUID, owner *uuid.UUID) (bool, error) {
+	sch, ok := m.schedules[id]
+	if !ok || (owner != nil && sch.OwnerID != *owner) {
+		return false, nil
+	}
+	delete(m.schedules, id)
+	return true, nil
+}
+
+func (m *memorySchedules) ListDueReportSchedules(_ context.Context, period time.Time, _ int) ([]domain.ReportSchedule, error) {
+	var out []domain.ReportSchedule
+	for _, sch := range m.schedules {
+		if sch.LastPeriod == nil || sch.LastPeriod.Before(period) {
+			out = append(out, *sch)
+		}
+	}
+	return out, nil
+}
+
+func (m *memorySchedules) QueueScheduledReport(ctx context.Context, id uuid.UUID, period time.Time, j *domain.ExportJob) (bool, error) {
+	sch := m.schedules[id]
+	if sch.LastPeriod != nil && !sch.LastPeriod.Before(period) {
+		return false, nil
+	}
+	p := period
+	sch.LastPeriod = &p
+	return true, m.repo.CreateExportJob(ctx, j)
+}
+
+type recordingNotifier struct {
+	jobs  []*domain.ExportJob
+	links []string
+}
+
+func (n *recordingNotifier) PublishReport(_ context.Context, j *domain.ExportJob, link string, _ time.Time) error {
+	n.jobs = append(n.jobs, j)
+	n.links = append(n.links, link)
+	return nil
+}
+
+func newReportService(t *testing.T) (*Service, *memoryRepository, *recordingSource, *recordingNotifier) {
+	repo := newMemoryRepository()
+	src := &recordingSource{sliceSource: sliceSource{rows: [][]string{{"a", "1"}}}}
+	notifier := &recordingNotifier{}
+	cfg := config.ExportConfig{
+		Dir:         t.TempDir(),
+		Retention:   24 * time.Hour,
+		URLExpiry:   15 * time.Minute,
+		PublicURL:   "https://api.example.com",
+		ReportDelay: time.Hour,
+	}
+	s := NewService(repo, cfg, "secret", logger.NewNop()).
+		WithSource(domain.ExportKindSettlementReport, src).
+		WithSource(domain.ExportKindFeeReport, src).
+		WithSource(domain.ExportKindTransactions, src).
+		WithSchedules(&memorySchedules{repo: repo, schedules: map[uuid.UUID]*domain.ReportSchedule{}}).
+		WithNotifier(notifier)
+	return s, repo, src, notifier
+}
+
+func TestSubmitReport_ScopedToOwner(t *testing.T) {
+	s, repo, src, notifier := newReportService(t)
+	ctx := context.Background()
+	owner, other := uuid.New(), uuid.New()
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := s.SubmitReport(ctx, owner, ReportRequest{Kind: domain.ExportKindTransactions, From: from, To: from.Add(time.Hour)}, owner)
+	assert.ErrorIs(t, err, ErrUnknownKind)
+	_, err = s.SubmitReport(ctx, owner, ReportRequest{Kind: domain.ExportKindFeeReport, From: from, To: from}, owner)
+	assert.ErrorIs(t, err, ErrInvalidFilters)
+	_, err = s.SubmitReport(ctx, owner, ReportRequest{Kind: domain.ExportKindFeeReport, From: from, To: from.AddDate(0, 2, 0)}, owner)
+	assert.ErrorIs(t, err, ErrInvalidFilters)
+
+	j, err := s.SubmitReport(ctx, owner, ReportRequest{Kind: domain.ExportKindSettlementReport, From: from, To: from.AddDate(0, 0, 1), Currency: "mwk"}, owner)
+	require.NoError(t, err)
+	assert.Equal(t, "MWK", j.Filters.Currency)
+
+	// Even a job whose stored filters name someone else only reads the
+	// owner's rows.
+	repo.jobs[j.ID].Filters.UserID = &other
+	require.True(t, s.RunNext(ctx))
+	require.Len(t, src.filters, 1)
+	assert.Equal(t, owner, *src.filters[0].UserID)
+
+	_, err = s.GetReport(ctx, other, j.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	got, err := s.GetReport(ctx, owner, j.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ExportStatusCompleted, got.Status)
+
+	mine, _, err := s.ListReports(ctx, owner, 50, 0)
+	require.NoError(t, err)
+	assert.Len(t, mine, 1)
+	theirs, _, err := s.ListReports(ctx, other, 50, 0)
+	require.NoError(t, err)
+	assert.Empty(t, theirs)
+
+	// The owner is sent a link that lasts as long as the file.
+	require.Len(t, notifier.links, 1)
+	u, err := url.Parse(notifier.links[0])
+	require.NoError(t, err)
+	assert.Equal(t, "api.example.com", u.Host)
+	f, _, err := s.Open(ctx, j.ID, u.Query().Get("expires"), u.Query().Get("signature"))
+	require.NoError(t, err)
+	f.Close()
+	s.now = func() time.Time { return time.Now().Add(20 * time.Hour) }
+	f, _, err = s.Open(ctx, j.ID, u.Query().Get("expires"), u.Query().Get("signature"))
+	require.NoError(t, err)
+	f.Close()
+}
+
+func TestAdminExportsAreNotPushed(t *testing.T) {
+	s, _, _, notifier := newReportService(t)
+	ctx := context.Background()
+	_, err := s.Submit(ctx, domain.ExportKindTransactions, domain.ExportFilters{}, uuid.New())
+	require.NoError(t, err)
+	require.True(t, s.RunNext(ctx))
+	assert.Empty(t, notifier.jobs)
+}
+
+func TestScheduledReportsQueueOncePerDay(t *testing.T) {
+	s, repo, _, _ := newReportService(t)
+	ctx := context.Background()
+	owner := uuid.New()
+	now := time.Date(2026, 3, 10, 0, 30, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	sch, err := s.CreateSchedule(ctx, owner, ScheduleRequest{Kind: domain.ExportKindFeeReport, Currency: "usd"}, owner)
+	require.NoError(t, err)
+	assert.Equal(t, "USD", sch.Currency)
+	_, err = s.CreateSchedule(ctx, owner, ScheduleRequest{Kind: domain.ExportKindFeeReport, Currency: "USD"}, owner)
+	assert.ErrorIs(t, err, ErrScheduleExists)
+	_, err = s.CreateSchedule(ctx, owner, ScheduleRequest{Kind: domain.ExportKindAuditLogs}, owner)
+	assert.ErrorIs(t, err, ErrUnknownKind)
+
+	// Within the delay after midnight the latest finished day is the 8th.
+	require.Equal(t, 1, s.QueueScheduledReports(ctx))
+	assert.Equal(t, 0, s.QueueScheduledReports(ctx))
+	var jobs []*domain.ExportJob
+	for _, j := range repo.jobs {
+		jobs = append(jobs, j)
+	}
+	require.Len(t, jobs, 1)
+	assert.Equal(t, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), *jobs[0].Filters.From)
+	assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), *jobs[0].Filters.To)
+	assert.Equal(t, owner, *jobs[0].OwnerID)
+	assert.Equal(t, "USD", jobs[0].Filters.Currency)
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, 1, s.QueueScheduledReports(ctx))
+	assert.Equal(t, 0, s.QueueScheduledReports(ctx))
+
+	other := uuid.New()
+	assert.ErrorIs(t, s.DeleteSchedule(ctx, &other, sch.ID), ErrScheduleNotFound)
+	require.NoError(t, s.DeleteSchedule(ctx, &owner, sch.ID))
+	now = now.AddDate(0, 0, 1)
+	assert.Equal(t, 0, s.QueueScheduledReports(ctx))
+}
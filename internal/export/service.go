@@ -0,0 +1,380 @@
+// Package export runs long admin exports in the background and serves the
+// resulting files through short-lived signed download links.
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound       = errors.New("export job not found")
+	ErrUnknownKind    = errors.New("unknown export kind")
+	ErrInvalidFilters = errors.New("invalid export filters")
+	ErrNotReady       = errors.New("export is not ready for download")
+	ErrInvalidLink    = errors.New("download link is invalid or has expired")
+)
+
+// Cursor is the position of the last exported row. Sources page in
+// (created_at, id) order so that rows inserted during an export are not
+// skipped or duplicated.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Query selects the next page of rows for an export.
+type Query struct {
+	Filters domain.ExportFilters
+	After   *Cursor
+	Limit   int
+}
+
+// Source produces the rows of one kind of export.
+type Source interface {
+	Columns() []string
+	Count(ctx context.Context, f domain.ExportFilters) (int, error)
+	// Page returns up to q.Limit rows after q.After and the cursor of the
+	// last one; an empty page ends the export.
+	Page(ctx context.Context, q Query) ([][]string, *Cursor, error)
+}
+
+type Repository interface {
+	CreateExportJob(ctx context.Context, j *domain.ExportJob) error
+	GetExportJob(ctx context.Context, id uuid.UUID) (*domain.ExportJob, error)
+	// ListExportJobs returns the owner's jobs, or every job when owner is
+	// nil, newest first, and how many there are.
+	ListExportJobs(ctx context.Context, owner *uuid.UUID, limit, offset int) ([]domain.ExportJob, int, error)
+	// ClaimExportJob moves the oldest queued job, or a running job whose
+	// worker stopped updating it before staleBefore, to running. It returns
+	// nil when there is nothing to do.
+	ClaimExportJob(ctx context.Context, now, staleBefore time.Time) (*domain.ExportJob, error)
+	UpdateExportProgress(ctx context.Context, id uuid.UUID, processed, total int) error
+	CompleteExportJob(ctx context.Context, j *domain.ExportJob) error
+	FailExportJob(ctx context.Context, id uuid.UUID, reason string) error
+	ListExpiredExportJobs(ctx context.Context, now time.Time, limit int) ([]domain.ExportJob, error)
+	MarkExportJobExpired(ctx context.Context, id uuid.UUID) error
+}
+
+const (
+	pageSize = 1000
+	// jobLease is how long a running job may go without progress before
+	// another worker restarts it.
+	jobLease = 10 * time.Minute
+)
+
+type Service struct {
+	repo      Repository
+	sources   map[domain.ExportKind]Source
+	schedules ScheduleRepository
+	notifier  Notifier
+	cfg       config.ExportConfig
+	secret    []byte
+	logger    logger.Logger
+	now       func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewService creates an export service. secret signs download links.
+func NewService(repo Repository, cfg config.ExportConfig, secret string, log logger.Logger) *Service {
+	return &Service{
+		repo:    repo,
+		sources: make(map[domain.ExportKind]Source),
+		cfg:     cfg,
+		secret:  []byte(secret),
+		logger:  log,
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+}
+
+// WithSource registers the producer for an export kind.
+func (s *Service) WithSource(kind domain.ExportKind, src Source) *Service {
+	s.sources[kind] = src
+	return s
+}
+
+// Submit queues an export for the background worker.
+func (s *Service) Submit(ctx context.Context, kind domain.ExportKind, filters domain.ExportFilters, requestedBy uuid.UUID) (*domain.ExportJob, error) {
+	j, err := s.newJob(kind, filters, requestedBy, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateExportJob(ctx, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// newJob validates and builds a queued job. An owner's job is limited to
+// the owner's own rows.
+func (s *Service) newJob(kind domain.ExportKind, filters domain.ExportFilters, requestedBy uuid.UUID, owner *uuid.UUID) (*domain.ExportJob, error) {
+	if _, ok := s.sources[kind]; !ok {
+		return nil, ErrUnknownKind
+	}
+	if filters.From != nil && filters.To != nil && filters.To.Before(*filters.From) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidFilters)
+	}
+	if owner != nil {
+		filters.UserID = owner
+	}
+	now := s.now()
+	return &domain.ExportJob{
+		ID:          uuid.New(),
+		Kind:        kind,
+		OwnerID:     owner,
+		Filters:     filters,
+		Status:      domain.ExportStatusQueued,
+		RequestedBy: requestedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.ExportJob, error) {
+	return s.repo.GetExportJob(ctx, id)
+}
+
+func (s *Service) List(ctx context.Context, limit, offset int) ([]domain.ExportJob, int, error) {
+	return s.repo.ListExportJobs(ctx, nil, limit, offset)
+}
+
+func (s *Service) sign(id uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s|%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DownloadURL returns a signed, short-lived path for a completed export.
+func (s *Service) DownloadURL(j *domain.ExportJob) (string, time.Time, error) {
+	return s.downloadURL(j, s.cfg.URLExpiry)
+}
+
+func (s *Service) downloadURL(j *domain.ExportJob, ttl time.Duration) (string, time.Time, error) {
+	if j.Status != domain.ExportStatusCompleted {
+		return "", time.Time{}, ErrNotReady
+	}
+	expires := s.now().Add(ttl)
+	if j.ExpiresAt != nil && j.ExpiresAt.Before(expires) {
+		expires = *j.ExpiresAt
+	}
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("signature", s.sign(j.ID, expires.Unix()))
+	return "/api/v1/exports/" + j.ID.String() + "/download?" + q.Encode(), expires, nil
+}
+
+// Open verifies a signed download link and opens the export file. The
+// caller must close the file.
+func (s *Service) Open(ctx context.Context, id uuid.UUID, expires, signature string) (*os.File, *domain.ExportJob, error) {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() > exp {
+		return nil, nil, ErrInvalidLink
+	}
+	if !hmac.Equal([]byte(s.sign(id, exp)), []byte(signature)) {
+		return nil, nil, ErrInvalidLink
+	}
+	j, err := s.repo.GetExportJob(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if j.Status != domain.ExportStatusCompleted {
+		return nil, nil, ErrNotReady
+	}
+	f, err := os.Open(j.FilePath)
+	if err != nil {
+		return nil, nil, ErrNotReady
+	}
+	return f, j, nil
+}
+
+// FileName is the name offered to the browser for a downloaded export.
+func FileName(j *domain.ExportJob) string {
+	return fmt.Sprintf("%s-%s.csv", j.Kind, j.CreatedAt.UTC().Format("20060102-150405"))
+}
+
+// Start runs the export worker until Stop is called.
+func (s *Service) Start() {
+	if err := os.MkdirAll(s.cfg.Dir, 0o700); err != nil {
+		s.logger.Error("Failed to create export directory", map[string]interface{}{"error": err.Error(), "dir": s.cfg.Dir})
+	}
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx := context.Background()
+				s.CleanupExpired(ctx)
+				s.QueueScheduledReports(ctx)
+				for s.RunNext(ctx) {
+					// drain the queue before sleeping again
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Export worker started", map[string]interface{}{"dir": s.cfg.Dir})
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// RunNext claims and runs one export job, reporting whether there was one.
+func (s *Service) RunNext(ctx context.Context) bool {
+	now := s.now()
+	j, err := s.repo.ClaimExportJob(ctx, now, now.Add(-jobLease))
+	if err != nil {
+		s.logger.Error("Failed to claim export job", map[string]interface{}{"error": err.Error()})
+		return false
+	}
+	if j == nil {
+		return false
+	}
+	if err := s.run(ctx, j); err != nil {
+		s.logger.Error("Export job failed", map[string]interface{}{"error": err.Error(), "job_id": j.ID})
+		if ferr := s.repo.FailExportJob(ctx, j.ID, err.Error()); ferr != nil {
+			s.logger.Error("Failed to mark export job failed", map[string]interface{}{"error": ferr.Error(), "job_id": j.ID})
+		}
+	}
+	return true
+}
+
+// run writes the export to a partial file and renames it into place once
+// complete, so a download never sees a truncated file. A restarted job
+// starts over from the beginning.
+func (s *Service) run(ctx context.Context, j *domain.ExportJob) error {
+	src, ok := s.sources[j.Kind]
+	if !ok {
+		return ErrUnknownKind
+	}
+	// Re-applied here so that an owner's job can never read other rows,
+	// whatever was stored in its filters.
+	if j.OwnerID != nil {
+		j.Filters.UserID = j.OwnerID
+	}
+	total, err := src.Count(ctx, j.Filters)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdateExportProgress(ctx, j.ID, 0, total); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.cfg.Dir, 0o700); err != nil {
+		return err
+	}
+	final := filepath.Join(s.cfg.Dir, j.ID.String()+".csv")
+	partial := final + ".part"
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partial)
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(src.Columns()); err != nil {
+		return err
+	}
+	processed := 0
+	var after *Cursor
+	for {
+		rows, last, err := src.Page(ctx, Query{Filters: j.Filters, After: after, Limit: pageSize})
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		processed += len(rows)
+		after = last
+		if total < processed {
+			total = processed
+		}
+		if err := s.repo.UpdateExportProgress(ctx, j.ID, processed, total); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partial, final); err != nil {
+		return err
+	}
+
+	now := s.now()
+	expires := now.Add(s.cfg.Retention)
+	j.Status = domain.ExportStatusCompleted
+	j.TotalRows = processed
+	j.ProcessedRows = processed
+	j.FilePath = final
+	j.FileSize = info.Size()
+	j.CompletedAt = &now
+	j.ExpiresAt = &expires
+	j.UpdatedAt = now
+	if err := s.repo.CompleteExportJob(ctx, j); err != nil {
+		os.Remove(final)
+		return err
+	}
+	s.logger.Info("Export completed", map[string]interface{}{
+		"job_id": j.ID,
+		"kind":   j.Kind,
+		"rows":   processed,
+		"bytes":  j.FileSize,
+	})
+	s.notifyReady(ctx, j)
+	return nil
+}
+
+// CleanupExpired deletes export files past their retention period.
+func (s *Service) CleanupExpired(ctx context.Context) {
+	jobs, err := s.repo.ListExpiredExportJobs(ctx, s.now(), 100)
+	if err != nil {
+		s.logger.Error("Failed to list expired exports", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	for _, j := range jobs {
+		if j.FilePath != "" {
+			if err := os.Remove(j.FilePath); err != nil && !os.IsNotExist(err) {
+				s.logger.Error("Failed to delete expired export", map[string]interface{}{"error": err.Error(), "job_id": j.ID})
+				continue
+			}
+		}
+		if err := s.repo.MarkExportJobExpired(ctx, j.ID); err != nil {
+			s.logger.Error("Failed to mark export expired", map[string]interface{}{"error": err.Error(), "job_id": j.ID})
+		}
+	}
+}
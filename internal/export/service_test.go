@@ -0,0 +1,213 @@
+package export
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRepository struct {
+	jobs map[uuid.UUID]*domain.ExportJob
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{jobs: make(map[uuid.UUID]*domain.ExportJob)}
+}
+
+func (r *memoryRepository) CreateExportJob(_ context.Context, j *domain.ExportJob) error {
+	r.jobs[j.ID] = j
+	return nil
+}
+
+func (r *memoryRepository) GetExportJob(_ context.Context, id uuid.UUID) (*domain.ExportJob, error) {
+	if j, ok := r.jobs[id]; ok {
+		return j, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryRepository) ListExportJobs(_ context.Context, owner *uuid.UUID, _, _ int) ([]domain.ExportJob, int, error) {
+	var out []domain.ExportJob
+	for _, j := range r.jobs {
+		if owner == nil || (j.OwnerID != nil && *j.OwnerID == *owner) {
+			out = append(out, *j)
+		}
+	}
+	return out, len(out), nil
+}
+
+func (r *memoryRepository) ClaimExportJob(_ context.Context, _, _ time.Time) (*domain.ExportJob, error) {
+	for _, j := range r.jobs {
+		if j.Status == domain.ExportStatusQueued {
+			j.Status = domain.ExportStatusRunning
+			return j, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRepository) UpdateExportProgress(_ context.Context, id uuid.UUID, processed, total int) error {
+	r.jobs[id].ProcessedRows = processed
+	r.jobs[id].TotalRows = total
+	return nil
+}
+
+func (r *memoryRepository) CompleteExportJob(_ context.Context, j *domain.ExportJob) error {
+	r.jobs[j.ID] = j
+	return nil
+}
+
+func (r *memoryRepository) FailExportJob(_ context.Context, id uuid.UUID, reason string) error {
+	r.jobs[id].Status = domain.ExportStatusFailed
+	r.jobs[id].Error = &reason
+	return nil
+}
+
+func (r *memoryRepository) ListExpiredExportJobs(_ context.Context, now time.Time, _ int) ([]domain.ExportJob, error) {
+	var out []domain.ExportJob
+	for _, j := range r.jobs {
+		if j.Status == domain.ExportStatusCompleted && j.ExpiresAt != nil && j.ExpiresAt.Before(now) {
+			out = append(out, *j)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) MarkExportJobExpired(_ context.Context, id uuid.UUID) error {
+	r.jobs[id].Status = domain.ExportStatusExpired
+	return nil
+}
+
+// sliceSource pages over a fixed set of rows, using the row index as cursor.
+type sliceSource struct {
+	rows [][]string
+}
+
+func (s *sliceSource) Columns() []string { return []string{"id", "value"} }
+
+func (s *sliceSource) Count(context.Context, domain.ExportFilters) (int, error) {
+	return len(s.rows), nil
+}
+
+func (s *sliceSource) Page(_ context.Context, q Query) ([][]string, *Cursor, error) {
+	start := 0
+	if q.After != nil {
+		start = int(q.After.CreatedAt.Unix())
+	}
+	end := start + q.Limit
+	if end > len(s.rows) {
+		end = len(s.rows)
+	}
+	if start >= end {
+		return nil, nil, nil
+	}
+	return s.rows[start:end], &Cursor{CreatedAt: time.Unix(int64(end), 0)}, nil
+}
+
+func newTestService(t *testing.T, rows int) (*Service, *memoryRepository) {
+	src := &sliceSource{}
+	for i := 0; i < rows; i++ {
+		src.rows = append(src.rows, []string{uuid.NewString(), "v"})
+	}
+	repo := newMemoryRepository()
+	cfg := config.ExportConfig{Dir: t.TempDir(), Retention: time.Hour, URLExpiry: 15 * time.Minute}
+	s := NewService(repo, cfg, "secret", logger.NewNop()).
+		WithSource(domain.ExportKindTransactions, src)
+	return s, repo
+}
+
+func TestRunNext_WritesCSVAndCompletes(t *testing.T) {
+	s, repo := newTestService(t, 2500)
+	ctx := context.Background()
+
+	j, err := s.Submit(ctx, domain.ExportKindTransactions, domain.ExportFilters{}, uuid.New())
+	require.NoError(t, err)
+	assert.True(t, s.RunNext(ctx))
+	assert.False(t, s.RunNext(ctx))
+
+	done := repo.jobs[j.ID]
+	assert.Equal(t, domain.ExportStatusCompleted, done.Status)
+	assert.Equal(t, 2500, done.ProcessedRows)
+	assert.InDelta(t, 1, done.Progress(), 0.001)
+
+	data, err := os.ReadFile(done.FilePath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2501)
+	assert.Equal(t, "id,value", lines[0])
+	assert.Equal(t, int64(len(data)), done.FileSize)
+}
+
+func TestSubmit_RejectsUnknownKindAndBadRange(t *testing.T) {
+	s, _ := newTestService(t, 0)
+	ctx := context.Background()
+
+	_, err := s.Submit(ctx, domain.ExportKindAuditLogs, domain.ExportFilters{}, uuid.New())
+	assert.ErrorIs(t, err, ErrUnknownKind)
+
+	from := time.Now()
+	to := from.Add(-time.Hour)
+	_, err = s.Submit(ctx, domain.ExportKindTransactions, domain.ExportFilters{From: &from, To: &to}, uuid.New())
+	assert.ErrorIs(t, err, ErrInvalidFilters)
+}
+
+func TestDownloadLink(t *testing.T) {
+	s, repo := newTestService(t, 3)
+	ctx := context.Background()
+
+	j, err := s.Submit(ctx, domain.ExportKindTransactions, domain.ExportFilters{}, uuid.New())
+	require.NoError(t, err)
+	_, _, err = s.DownloadURL(j)
+	assert.ErrorIs(t, err, ErrNotReady)
+
+	s.RunNext(ctx)
+	link, _, err := s.DownloadURL(repo.jobs[j.ID])
+	require.NoError(t, err)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	expires, sig := u.Query().Get("expires"), u.Query().Get("signature")
+
+	f, _, err := s.Open(ctx, j.ID, expires, sig)
+	require.NoError(t, err)
+	f.Close()
+
+	_, _, err = s.Open(ctx, j.ID, expires, strings.Repeat("0", len(sig)))
+	assert.ErrorIs(t, err, ErrInvalidLink)
+	_, _, err = s.Open(ctx, uuid.New(), expires, sig)
+	assert.ErrorIs(t, err, ErrInvalidLink)
+
+	s.now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, _, err = s.Open(ctx, j.ID, expires, sig)
+	assert.ErrorIs(t, err, ErrInvalidLink)
+}
+
+func TestCleanupExpired(t *testing.T) {
+	s, repo := newTestService(t, 3)
+	ctx := context.Background()
+
+	j, err := s.Submit(ctx, domain.ExportKindTransactions, domain.ExportFilters{}, uuid.New())
+	require.NoError(t, err)
+	s.RunNext(ctx)
+	path := repo.jobs[j.ID].FilePath
+
+	s.CleanupExpired(ctx)
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	s.CleanupExpired(ctx)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, domain.ExportStatusExpired, repo.jobs[j.ID].Status)
+}
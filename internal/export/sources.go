@@ -0,0 +1,236 @@
+package export
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// AuditLogRepository pages through audit logs for export.
+type AuditLogRepository interface {
+	CountAuditLogsForExport(ctx context.Context, f domain.ExportFilters) (int, error)
+	ExportAuditLogs(ctx context.Context, q Query) ([]*domain.AuditLog, error)
+}
+
+// TransactionRepository pages through transactions for export.
+type TransactionRepository interface {
+	CountTransactionsForExport(ctx context.Context, f domain.ExportFilters) (int, error)
+	ExportTransactions(ctx context.Context, q Query) ([]*domain.Transaction, error)
+}
+
+// ReportRepository pages through the rows of partner reports. A filter
+// UserID limits rows to that account: both sides of a payment for the
+// settlement report, the paying side for the fee report.
+type ReportRepository interface {
+	CountSettlementReport(ctx context.Context, f domain.ExportFilters) (int, error)
+	SettlementReport(ctx context.Context, q Query) ([]*domain.SettlementReportRow, error)
+	CountFeeReport(ctx context.Context, f domain.ExportFilters) (int, error)
+	FeeReport(ctx context.Context, q Query) ([]*domain.Transaction, error)
+}
+
+// InternalNoteRepository supplies support notes for exported transactions,
+// joined into one field per transaction.
+type InternalNoteRepository interface {
+	ExportInternalNotes(ctx context.Context, txIDs []uuid.UUID) (map[uuid.UUID]string, error)
+}
+
+type auditLogSource struct {
+	repo AuditLogRepository
+}
+
+func NewAuditLogSource(repo AuditLogRepository) Source {
+	return &auditLogSource{repo: repo}
+}
+
+func (s *auditLogSource) Columns() []string {
+	return []string{
+		"id", "created_at", "user_id", "user_email", "action", "entity_type", "entity_id",
+		"status_code", "ip_address", "user_agent", "error_message", "old_values", "new_values",
+	}
+}
+
+func (s *auditLogSource) Count(ctx context.Context, f domain.ExportFilters) (int, error) {
+	return s.repo.CountAuditLogsForExport(ctx, f)
+}
+
+func (s *auditLogSource) Page(ctx context.Context, q Query) ([][]string, *Cursor, error) {
+	logs, err := s.repo.ExportAuditLogs(ctx, q)
+	if err != nil || len(logs) == 0 {
+		return nil, nil, err
+	}
+	rows := make([][]string, 0, len(logs))
+	for _, l := range logs {
+		userID := ""
+		if l.UserID != nil {
+			userID = l.UserID.String()
+		}
+		rows = append(rows, []string{
+			l.ID.String(), l.CreatedAt.UTC().Format(time.RFC3339), userID, l.UserEmail, l.Action,
+			l.EntityType, l.EntityID, strconv.Itoa(l.StatusCode), l.IPAddress, l.UserAgent,
+			l.ErrorMessage, string(l.OldValues), string(l.NewValues),
+		})
+	}
+	last := logs[len(logs)-1]
+	return rows, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+type transactionSource struct {
+	repo  TransactionRepository
+	notes InternalNoteRepository
+}
+
+// NewTransactionSource exports transactions. With notes, each row carries
+// the transaction's internal support notes; customers' private notes are
+// never exported.
+func NewTransactionSource(repo TransactionRepository, notes InternalNoteRepository) Source {
+	return &transactionSource{repo: repo, notes: notes}
+}
+
+func (s *transactionSource) Columns() []string {
+	return []string{
+		"id", "reference", "created_at", "completed_at", "status", "transaction_type", "channel",
+		"sender_id", "receiver_id", "amount", "currency", "exchange_rate", "converted_amount",
+		"converted_currency", "fee_amount", "fee_currency", "net_amount", "settlement_id",
+		"blockchain_tx_hash", "status_reason", "internal_notes",
+	}
+}
+
+func (s *transactionSource) Count(ctx context.Context, f domain.ExportFilters) (int, error) {
+	return s.repo.CountTransactionsForExport(ctx, f)
+}
+
+func (s *transactionSource) Page(ctx context.Context, q Query) ([][]string, *Cursor, error) {
+	txs, err := s.repo.ExportTransactions(ctx, q)
+	if err != nil || len(txs) == 0 {
+		return nil, nil, err
+	}
+	notes := map[uuid.UUID]string{}
+	if s.notes != nil {
+		ids := make([]uuid.UUID, len(txs))
+		for i, tx := range txs {
+			ids[i] = tx.ID
+		}
+		if notes, err = s.notes.ExportInternalNotes(ctx, ids); err != nil {
+			return nil, nil, err
+		}
+	}
+	rows := make([][]string, 0, len(txs))
+	for _, tx := range txs {
+		completedAt, settlementID := "", ""
+		if tx.CompletedAt != nil {
+			completedAt = tx.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		if tx.SettlementID != nil {
+			settlementID = tx.SettlementID.String()
+		}
+		rows = append(rows, []string{
+			tx.ID.String(), tx.Reference, tx.CreatedAt.UTC().Format(time.RFC3339), completedAt,
+			string(tx.Status), string(tx.TransactionType), tx.Channel,
+			tx.SenderID.String(), tx.ReceiverID.String(), tx.Amount.String(), string(tx.Currency),
+			tx.ExchangeRate.String(), tx.ConvertedAmount.String(), string(tx.ConvertedCurrency),
+			tx.FeeAmount.String(), string(tx.FeeCurrency), tx.NetAmount.String(), settlementID,
+			tx.BlockchainTxHash, tx.StatusReason, notes[tx.ID],
+		})
+	}
+	last := txs[len(txs)-1]
+	return rows, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+type settlementReportSource struct {
+	repo ReportRepository
+}
+
+// NewSettlementReportSource exports completed transactions with the
+// settlement batch that carried each one. For an account's report, each row
+// says whether the account sent or received the payment.
+func NewSettlementReportSource(repo ReportRepository) Source {
+	return &settlementReportSource{repo: repo}
+}
+
+func (s *settlementReportSource) Columns() []string {
+	return []string{
+		"id", "reference", "created_at", "completed_at", "direction", "counterparty_id",
+		"amount", "currency", "converted_amount", "converted_currency", "fee_amount", "fee_currency",
+		"net_amount", "settlement_id", "settlement_batch", "settlement_status", "settlement_network",
+		"blockchain_tx_hash",
+	}
+}
+
+func (s *settlementReportSource) Count(ctx context.Context, f domain.ExportFilters) (int, error) {
+	return s.repo.CountSettlementReport(ctx, f)
+}
+
+func (s *settlementReportSource) Page(ctx context.Context, q Query) ([][]string, *Cursor, error) {
+	txs, err := s.repo.SettlementReport(ctx, q)
+	if err != nil || len(txs) == 0 {
+		return nil, nil, err
+	}
+	rows := make([][]string, 0, len(txs))
+	for _, tx := range txs {
+		direction, counterparty := "", ""
+		if user := q.Filters.UserID; user != nil {
+			direction, counterparty = "sent", tx.ReceiverID.String()
+			if tx.ReceiverID == *user {
+				direction, counterparty = "received", tx.SenderID.String()
+			}
+		}
+		completedAt, settlementID := "", ""
+		if tx.CompletedAt != nil {
+			completedAt = tx.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		if tx.SettlementID != nil {
+			settlementID = tx.SettlementID.String()
+		}
+		rows = append(rows, []string{
+			tx.ID.String(), tx.Reference, tx.CreatedAt.UTC().Format(time.RFC3339), completedAt,
+			direction, counterparty, tx.Amount.String(), string(tx.Currency),
+			tx.ConvertedAmount.String(), string(tx.ConvertedCurrency), tx.FeeAmount.String(), string(tx.FeeCurrency),
+			tx.NetAmount.String(), settlementID, tx.SettlementBatch, tx.SettlementStatus, tx.SettlementNetwork,
+			tx.BlockchainTxHash,
+		})
+	}
+	last := txs[len(txs)-1]
+	return rows, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+type feeReportSource struct {
+	repo ReportRepository
+}
+
+// NewFeeReportSource exports the fees charged on payments, one row per
+// payment that carried a fee.
+func NewFeeReportSource(repo ReportRepository) Source {
+	return &feeReportSource{repo: repo}
+}
+
+func (s *feeReportSource) Columns() []string {
+	return []string{
+		"id", "reference", "created_at", "status", "transaction_type", "sender_id", "receiver_id",
+		"amount", "currency", "fee_amount", "fee_currency",
+	}
+}
+
+func (s *feeReportSource) Count(ctx context.Context, f domain.ExportFilters) (int, error) {
+	return s.repo.CountFeeReport(ctx, f)
+}
+
+func (s *feeReportSource) Page(ctx context.Context, q Query) ([][]string, *Cursor, error) {
+	txs, err := s.repo.FeeReport(ctx, q)
+	if err != nil || len(txs) == 0 {
+		return nil, nil, err
+	}
+	rows := make([][]string, 0, len(txs))
+	for _, tx := range txs {
+		rows = append(rows, []string{
+			tx.ID.String(), tx.Reference, tx.CreatedAt.UTC().Format(time.RFC3339), string(tx.Status),
+			string(tx.TransactionType), tx.SenderID.String(), tx.ReceiverID.String(),
+			tx.Amount.String(), string(tx.Currency), tx.FeeAmount.String(), string(tx.FeeCurrency),
+		})
+	}
+	last := txs[len(txs)-1]
+	return rows, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
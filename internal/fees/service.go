@@ -0,0 +1,319 @@
+// Package fees manages the fee schedules payments are priced by.
+//
+// Schedules are few and change rarely, so each instance keeps the current
+// and future ones in memory and refreshes them every cache TTL; pricing a
+// payment then costs no query. Schedules already in effect are never
+// edited, so what a payment was charged can always be traced to the terms
+// of the schedule recorded on it: they are ended, or superseded by a newer
+// schedule for the same payments, instead.
+package fees
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("fee schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid fee schedule")
+	// ErrScheduleInEffect is returned for changes only allowed to schedules
+	// that have not yet taken effect.
+	ErrScheduleInEffect = errors.New("fee schedule is already in effect")
+)
+
+// Repository persists fee schedules.
+type Repository interface {
+	Create(ctx context.Context, f *domain.FeeSchedule) error
+	Update(ctx context.Context, f *domain.FeeSchedule) error
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+	// Find returns the schedule, or nil.
+	Find(ctx context.Context, id uuid.UUID) (*domain.FeeSchedule, error)
+	// List returns the schedules still in effect or yet to take effect at
+	// since, or every schedule when since is nil, latest first.
+	List(ctx context.Context, since *time.Time) ([]domain.FeeSchedule, error)
+}
+
+const defaultCacheTTL = time.Minute
+
+type Service struct {
+	repo   Repository
+	logger logger.Logger
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	cache    []domain.FeeSchedule
+	loadedAt time.Time
+}
+
+func NewService(repo Repository, log logger.Logger) *Service {
+	return &Service{repo: repo, logger: log, ttl: defaultCacheTTL, now: time.Now}
+}
+
+// WithCacheTTL sets how long schedules are served from memory. Changes made
+// on another instance take up to this long to apply here.
+func (s *Service) WithCacheTTL(d time.Duration) *Service {
+	if d > 0 {
+		s.ttl = d
+	}
+	return s
+}
+
+// ScheduleRequest creates a fee schedule or replaces one not yet in effect.
+// Omitted criteria match any payment; an omitted effective_from is now.
+type ScheduleRequest struct {
+	Name                string           `json:"name"`
+	SourceCurrency      *domain.Currency `json:"source_currency"`
+	DestinationCurrency *domain.Currency `json:"destination_currency"`
+	Channel             *string          `json:"channel"`
+	UserType            *domain.UserType `json:"user_type"`
+	KYCLevel            *int             `json:"kyc_level"`
+	FlatFee             decimal.Decimal  `json:"flat_fee"`
+	Percentage          decimal.Decimal  `json:"percentage"`
+	MinFee              *decimal.Decimal `json:"min_fee"`
+	MaxFee              *decimal.Decimal `json:"max_fee"`
+	EffectiveFrom       *time.Time       `json:"effective_from"`
+	EffectiveTo         *time.Time       `json:"effective_to"`
+}
+
+func (s *Service) Create(ctx context.Context, req ScheduleRequest, createdBy uuid.UUID) (*domain.FeeSchedule, error) {
+	now := s.now().UTC()
+	f := &domain.FeeSchedule{ID: uuid.New(), CreatedBy: &createdBy, CreatedAt: now, UpdatedAt: now}
+	if err := apply(f, req, now); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, f); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return f, nil
+}
+
+// Update replaces a schedule that has not yet taken effect.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, req ScheduleRequest) (*domain.FeeSchedule, error) {
+	f, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	if !now.Before(f.EffectiveFrom) {
+		return nil, fmt.Errorf("%w: end it or supersede it with a new schedule instead", ErrScheduleInEffect)
+	}
+	if err := apply(f, req, now); err != nil {
+		return nil, err
+	}
+	f.UpdatedAt = now
+	if err := s.repo.Update(ctx, f); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return f, nil
+}
+
+// End stops a schedule applying from at, or from now when at is nil.
+func (s *Service) End(ctx context.Context, id uuid.UUID, at *time.Time) (*domain.FeeSchedule, error) {
+	f, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	end := now
+	if at != nil {
+		end = at.UTC()
+	}
+	switch {
+	case f.EffectiveTo != nil && !f.EffectiveTo.After(now):
+		return nil, fmt.Errorf("%w: it has already ended", ErrInvalidSchedule)
+	case end.Before(now):
+		return nil, fmt.Errorf("%w: effective_to must not be in the past", ErrInvalidSchedule)
+	case !end.After(f.EffectiveFrom):
+		return nil, fmt.Errorf("%w: effective_to must be after effective_from; delete a schedule that never took effect", ErrInvalidSchedule)
+	}
+	f.EffectiveTo = &end
+	f.UpdatedAt = now
+	if err := s.repo.Update(ctx, f); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return f, nil
+}
+
+// Delete removes a schedule that has not yet taken effect.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
+	f, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !s.now().Before(f.EffectiveFrom) {
+		return fmt.Errorf("%w: end it instead", ErrScheduleInEffect)
+	}
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrScheduleNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.FeeSchedule, error) {
+	f, err := s.repo.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, ErrScheduleNotFound
+	}
+	return f, nil
+}
+
+// List returns the schedules in effect or yet to take effect, with ended
+// ones too when all is set.
+func (s *Service) List(ctx context.Context, all bool) ([]domain.FeeSchedule, error) {
+	if all {
+		return s.repo.List(ctx, nil)
+	}
+	now := s.now().UTC()
+	return s.repo.List(ctx, &now)
+}
+
+// SchedulesAt returns the schedules in effect at t, for pricing payments.
+func (s *Service) SchedulesAt(ctx context.Context, t time.Time) ([]domain.FeeSchedule, error) {
+	cached, err := s.fresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []domain.FeeSchedule
+	for i := range cached {
+		if cached[i].InEffect(t) {
+			out = append(out, cached[i])
+		}
+	}
+	return out, nil
+}
+
+func apply(f *domain.FeeSchedule, req ScheduleRequest, now time.Time) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 255 {
+		return fmt.Errorf("%w: name is required, up to 255 characters", ErrInvalidSchedule)
+	}
+	source, err := currency(req.SourceCurrency, "source_currency")
+	if err != nil {
+		return err
+	}
+	destination, err := currency(req.DestinationCurrency, "destination_currency")
+	if err != nil {
+		return err
+	}
+	var channel *string
+	if req.Channel != nil {
+		c := strings.ToLower(strings.TrimSpace(*req.Channel))
+		if c == "" || len(c) > 32 {
+			return fmt.Errorf("%w: channel must be 1 to 32 characters", ErrInvalidSchedule)
+		}
+		channel = &c
+	}
+	if t := req.UserType; t != nil && *t != domain.UserTypeIndividual && *t != domain.UserTypeMerchant && *t != domain.UserTypeAgent {
+		return fmt.Errorf("%w: user_type must be individual, merchant or agent", ErrInvalidSchedule)
+	}
+	if l := req.KYCLevel; l != nil && (*l < 0 || *l > 3) {
+		return fmt.Errorf("%w: kyc_level must be between 0 and 3", ErrInvalidSchedule)
+	}
+	if req.FlatFee.IsNegative() || (req.MinFee != nil && req.MinFee.IsNegative()) || (req.MaxFee != nil && req.MaxFee.IsNegative()) {
+		return fmt.Errorf("%w: fees must not be negative", ErrInvalidSchedule)
+	}
+	if req.Percentage.IsNegative() || req.Percentage.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return fmt.Errorf("%w: percentage must be at least 0 and below 1", ErrInvalidSchedule)
+	}
+	if req.MinFee != nil && req.MaxFee != nil && req.MinFee.GreaterThan(*req.MaxFee) {
+		return fmt.Errorf("%w: min_fee must not exceed max_fee", ErrInvalidSchedule)
+	}
+	if source == nil && (!req.FlatFee.IsZero() || req.MinFee != nil || req.MaxFee != nil) {
+		return fmt.Errorf("%w: source_currency is required with a flat fee or fee bounds", ErrInvalidSchedule)
+	}
+	from := now
+	if req.EffectiveFrom != nil {
+		from = req.EffectiveFrom.UTC()
+		if from.Before(now) {
+			return fmt.Errorf("%w: effective_from must not be in the past", ErrInvalidSchedule)
+		}
+	}
+	var to *time.Time
+	if req.EffectiveTo != nil {
+		t := req.EffectiveTo.UTC()
+		if !t.After(from) {
+			return fmt.Errorf("%w: effective_to must be after effective_from", ErrInvalidSchedule)
+		}
+		to = &t
+	}
+
+	f.Name = name
+	f.SourceCurrency = source
+	f.DestinationCurrency = destination
+	f.Channel = channel
+	f.UserType = req.UserType
+	f.KYCLevel = req.KYCLevel
+	f.FlatFee = req.FlatFee
+	f.Percentage = req.Percentage
+	f.MinFee = req.MinFee
+	f.MaxFee = req.MaxFee
+	f.EffectiveFrom = from
+	f.EffectiveTo = to
+	return nil
+}
+
+// currency upper-cases an optional ISO 4217 code.
+func currency(c *domain.Currency, field string) (*domain.Currency, error) {
+	if c == nil {
+		return nil, nil
+	}
+	code := domain.Currency(strings.ToUpper(strings.TrimSpace(string(*c))))
+	if len(code) != 3 {
+		return nil, fmt.Errorf("%w: %s must be a 3-letter currency code", ErrInvalidSchedule, field)
+	}
+	return &code, nil
+}
+
+// fresh returns the cached schedules, reloading them once the TTL has
+// passed.
+func (s *Service) fresh(ctx context.Context) ([]domain.FeeSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && s.now().Sub(s.loadedAt) < s.ttl {
+		return s.cache, nil
+	}
+	now := s.now().UTC()
+	schedules, err := s.repo.List(ctx, &now)
+	if err != nil {
+		if s.cache != nil {
+			// Serve the last good copy rather than fail every payment.
+			s.logger.Warn("Failed to refresh fee schedules, serving cached copy", map[string]interface{}{"error": err.Error()})
+			return s.cache, nil
+		}
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []domain.FeeSchedule{}
+	}
+	s.cache = schedules
+	s.loadedAt = s.now()
+	return schedules, nil
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,275 @@
+package fees
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, f *domain.FeeSchedule) error {
+	return m.Called(ctx, f).Error(0)
+}
+
+func (m *MockRepository) Update(ctx context.Context, f *domain.FeeSchedule) error {
+	return m.Called(ctx, f).Error(0)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Find(ctx context.Context, id uuid.UUID) (*domain.FeeSchedule, error) {
+	args := m.Called(ctx, id)
+	f, _ := args.Get(0).(*domain.FeeSchedule)
+	if f != nil {
+		cp := *f
+		f = &cp
+	}
+	return f, args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, since *time.Time) ([]domain.FeeSchedule, error) {
+	args := m.Called(ctx, since)
+	schedules, _ := args.Get(0).([]domain.FeeSchedule)
+	return schedules, args.Error(1)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *MockRepository) {
+	repo := &MockRepository{}
+	s := NewService(repo, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo
+}
+
+// at matches the since of a List at t.
+func at(t time.Time) interface{} {
+	return mock.MatchedBy(func(since *time.Time) bool { return since != nil && since.Equal(t) })
+}
+
+// creates records the schedules the service creates in *created.
+func creates(repo *MockRepository, created *[]domain.FeeSchedule) {
+	repo.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		*created = append(*created, *args.Get(1).(*domain.FeeSchedule))
+	}).Return(nil)
+}
+
+func dec(v string) *decimal.Decimal {
+	d := decimal.RequireFromString(v)
+	return &d
+}
+
+func TestScheduleSelectionAndBreakdown(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	var created []domain.FeeSchedule
+	creates(repo, &created)
+	mwk, zar := domain.MWK, domain.ZAR
+	merchant := domain.UserTypeMerchant
+	admin := uuid.New()
+
+	base, err := s.Create(ctx, ScheduleRequest{Name: "Default", Percentage: decimal.RequireFromString("0.02")}, admin)
+	require.NoError(t, err)
+	assert.Equal(t, admin, *base.CreatedBy)
+	assert.Equal(t, now, base.EffectiveFrom, "effective from now unless given")
+	corridor, err := s.Create(ctx, ScheduleRequest{
+		Name: "MWK to ZAR", SourceCurrency: &mwk, DestinationCurrency: &zar,
+		FlatFee: decimal.NewFromInt(500), Percentage: decimal.RequireFromString("0.01"),
+		MinFee: dec("1000"), MaxFee: dec("25000"),
+	}, admin)
+	require.NoError(t, err)
+	_, err = s.Create(ctx, ScheduleRequest{
+		Name: "Merchants to ZAR", SourceCurrency: &mwk, DestinationCurrency: &zar, UserType: &merchant,
+		Percentage: decimal.RequireFromString("0.005"),
+	}, admin)
+	require.NoError(t, err)
+
+	repo.On("List", ctx, at(now)).Return(created, nil).Once()
+	schedules, err := s.SchedulesAt(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, schedules, 3)
+	q := domain.FeeQuery{SourceCurrency: domain.MWK, DestinationCurrency: domain.ZAR, UserType: domain.UserTypeIndividual, KYCLevel: 2, At: now}
+
+	// The corridor schedule is more specific than the catch-all
+	f := domain.SelectFeeSchedule(schedules, q)
+	require.NotNil(t, f)
+	assert.Equal(t, corridor.ID, f.ID)
+
+	b := f.Breakdown(decimal.NewFromInt(100000), domain.MWK)
+	assert.Equal(t, "1000", b.PercentageFee.String())
+	assert.Equal(t, "1500", b.Fee.String(), "500 flat + 1% of 100,000")
+	assert.Empty(t, b.Capped)
+	assert.Equal(t, "101500", b.TotalDebit.String())
+	b = f.Breakdown(decimal.NewFromInt(20000), domain.MWK)
+	assert.Equal(t, "1000", b.Fee.String())
+	assert.Equal(t, "min", b.Capped)
+	b = f.Breakdown(decimal.NewFromInt(5000000), domain.MWK)
+	assert.Equal(t, "25000", b.Fee.String())
+	assert.Equal(t, "max", b.Capped)
+
+	// Merchants get their own rate; other corridors fall to the catch-all
+	q.UserType = domain.UserTypeMerchant
+	assert.Equal(t, "Merchants to ZAR", domain.SelectFeeSchedule(schedules, q).Name)
+	q.DestinationCurrency = domain.MWK
+	assert.Equal(t, base.ID, domain.SelectFeeSchedule(schedules, q).ID)
+
+	// A later schedule for the same payments supersedes the corridor one
+	// from its start
+	next := now.Add(24 * time.Hour)
+	_, err = s.Create(ctx, ScheduleRequest{
+		Name: "MWK to ZAR from tomorrow", SourceCurrency: &mwk, DestinationCurrency: &zar,
+		Percentage: decimal.RequireFromString("0.012"), EffectiveFrom: &next,
+	}, admin)
+	require.NoError(t, err)
+	repo.On("List", ctx, at(now)).Return(created, nil).Once()
+	q = domain.FeeQuery{SourceCurrency: domain.MWK, DestinationCurrency: domain.ZAR, At: now}
+	schedules, err = s.SchedulesAt(ctx, q.At)
+	require.NoError(t, err)
+	assert.Equal(t, corridor.ID, domain.SelectFeeSchedule(schedules, q).ID)
+	q.At = next
+	schedules, err = s.SchedulesAt(ctx, q.At)
+	require.NoError(t, err)
+	assert.Equal(t, "MWK to ZAR from tomorrow", domain.SelectFeeSchedule(schedules, q).Name)
+	repo.AssertExpectations(t)
+}
+
+func TestScheduleValidation(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	mwk := domain.MWK
+	past := now.Add(-time.Hour)
+	lvl := 4
+	bad := []ScheduleRequest{
+		{},
+		{Name: "Negative", Percentage: decimal.RequireFromString("-0.01")},
+		{Name: "Whole amount", Percentage: decimal.NewFromInt(1)},
+		{Name: "Flat without currency", FlatFee: decimal.NewFromInt(100)},
+		{Name: "Caps crossed", SourceCurrency: &mwk, MinFee: dec("500"), MaxFee: dec("100")},
+		{Name: "Backdated", EffectiveFrom: &past},
+		{Name: "Ends first", EffectiveTo: &past},
+		{Name: "KYC", KYCLevel: &lvl},
+	}
+	for _, req := range bad {
+		_, err := s.Create(ctx, req, uuid.New())
+		assert.ErrorIs(t, err, ErrInvalidSchedule, req.Name)
+	}
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestScheduleLifecycle(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	later := now.Add(48 * time.Hour)
+
+	t.Run("a pending schedule is edited or deleted", func(t *testing.T) {
+		pending := &domain.FeeSchedule{ID: uuid.New(), Name: "Promo", Percentage: decimal.RequireFromString("0.01"), EffectiveFrom: later}
+		repo.On("Find", ctx, pending.ID).Return(pending, nil)
+		repo.On("Update", ctx, mock.MatchedBy(func(f *domain.FeeSchedule) bool {
+			return f.ID == pending.ID && f.Percentage.String() == "0.008" && f.UpdatedAt.Equal(now)
+		})).Return(nil).Once()
+		repo.On("Delete", ctx, pending.ID).Return(true, nil).Once()
+
+		updated, err := s.Update(ctx, pending.ID, ScheduleRequest{Name: "Promo", Percentage: decimal.RequireFromString("0.008"), EffectiveFrom: &later})
+		require.NoError(t, err)
+		assert.Equal(t, "0.008", updated.Percentage.String())
+		require.NoError(t, s.Delete(ctx, pending.ID))
+		repo.AssertExpectations(t)
+
+		repo.On("Delete", ctx, pending.ID).Return(false, nil).Once()
+		assert.ErrorIs(t, s.Delete(ctx, pending.ID), ErrScheduleNotFound, "deleted meanwhile")
+	})
+
+	t.Run("schedules in effect are ended, never edited or deleted", func(t *testing.T) {
+		live := &domain.FeeSchedule{ID: uuid.New(), Name: "Standard", Percentage: decimal.RequireFromString("0.015"), EffectiveFrom: now.Add(-time.Hour)}
+		repo.On("Find", ctx, live.ID).Return(live, nil).Once()
+		_, err := s.Update(ctx, live.ID, ScheduleRequest{Name: "Standard", Percentage: decimal.RequireFromString("0.01")})
+		assert.ErrorIs(t, err, ErrScheduleInEffect)
+		repo.On("Find", ctx, live.ID).Return(live, nil).Once()
+		assert.ErrorIs(t, s.Delete(ctx, live.ID), ErrScheduleInEffect)
+
+		repo.On("Find", ctx, live.ID).Return(live, nil).Once()
+		var ended *domain.FeeSchedule
+		repo.On("Update", ctx, mock.MatchedBy(func(f *domain.FeeSchedule) bool { return f.ID == live.ID })).Run(func(args mock.Arguments) {
+			ended = args.Get(1).(*domain.FeeSchedule)
+		}).Return(nil).Once()
+		_, err = s.End(ctx, live.ID, nil)
+		require.NoError(t, err)
+		require.NotNil(t, ended)
+		assert.Equal(t, now, *ended.EffectiveTo)
+		repo.AssertExpectations(t)
+
+		now = now.Add(time.Minute)
+		repo.On("Find", ctx, live.ID).Return(ended, nil).Once()
+		_, err = s.End(ctx, live.ID, nil)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, "already ended")
+		past := now.Add(-time.Minute)
+		repo.On("Find", ctx, live.ID).Return(live, nil).Once()
+		_, err = s.End(ctx, live.ID, &past)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, "not backdated")
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		repo.On("List", ctx, (*time.Time)(nil)).Return([]domain.FeeSchedule{{ID: uuid.New()}}, nil).Once()
+		repo.On("List", ctx, at(now)).Return(nil, nil).Once()
+		all, err := s.List(ctx, true)
+		require.NoError(t, err)
+		assert.Len(t, all, 1)
+		current, err := s.List(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, current)
+
+		missing := uuid.New()
+		repo.On("Find", ctx, missing).Return(nil, nil).Once()
+		_, err = s.Get(ctx, missing)
+		assert.ErrorIs(t, err, ErrScheduleNotFound)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestSchedulesAreCached(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	standard := []domain.FeeSchedule{{ID: uuid.New(), Name: "Standard", Percentage: decimal.RequireFromString("0.015"), EffectiveFrom: now.Add(-time.Hour)}}
+	repo.On("List", ctx, at(now)).Return(standard, nil).Once()
+
+	for i := 0; i < 3; i++ {
+		schedules, err := s.SchedulesAt(ctx, now)
+		require.NoError(t, err)
+		assert.Len(t, schedules, 1)
+	}
+	repo.AssertExpectations(t)
+
+	now = now.Add(2 * defaultCacheTTL)
+	repo.On("List", ctx, at(now)).Return(nil, errors.New("db down")).Once()
+	schedules, err := s.SchedulesAt(ctx, now)
+	require.NoError(t, err, "the last good copy is served when a refresh fails")
+	assert.Len(t, schedules, 1)
+	repo.AssertExpectations(t)
+
+	repo.On("Create", ctx, mock.Anything).Return(nil).Once()
+	_, err = s.Create(ctx, ScheduleRequest{Name: "Promo", Percentage: decimal.RequireFromString("0.01")}, uuid.New())
+	require.NoError(t, err)
+	repo.On("List", ctx, at(now)).Return(nil, errors.New("db down")).Once()
+	_, err = s.SchedulesAt(ctx, now)
+	assert.Error(t, err, "a change drops the cache")
+	repo.AssertExpectations(t)
+}
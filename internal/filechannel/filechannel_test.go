@@ -0,0 +1,318 @@
+package filechannel
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePayer struct {
+	calls []*payment.BulkPaymentRequest
+}
+
+func (f *fakePayer) BulkPayment(ctx context.Context, req *payment.BulkPaymentRequest) (*payment.BulkPaymentResult, error) {
+	f.calls = append(f.calls, req)
+	res := &payment.BulkPaymentResult{TotalCount: len(req.Payments)}
+	for range req.Payments {
+		res.Successful = append(res.Successful, uuid.New())
+	}
+	return res, nil
+}
+
+type fakeUsers map[uuid.UUID]*domain.User
+
+func (f fakeUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	if u, ok := f[id]; ok {
+		return u, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func newTestService(t *testing.T, userType domain.UserType) (*Service, *DirStore, *fakePayer, string) {
+	t.Helper()
+	id := uuid.New()
+	store := NewDirStore(t.TempDir())
+	payer := &fakePayer{}
+	users := fakeUsers{id: {ID: id, UserType: userType, IsActive: true}}
+	return NewService(store, payer, users, time.Minute, logger.NewNop()), store, payer, id.String()
+}
+
+const validFile = `{"payments":[
+	{"receiver_id":"6f1c1a52-63a4-4d1e-9d6f-3f4f1c2a9b10","amount":"150.00","currency":"MWK","description":"Salary"},
+	{"receiver_id":"0b7e3a44-2c1d-4a8e-8f0e-5d6c7b8a9f21","amount":"75.50","currency":"MWK"}
+]}`
+
+func readAck(t *testing.T, store Store, customer, name string) Ack {
+	t.Helper()
+	data, err := store.Read(context.Background(), customer, FolderOutbound, AckName(name))
+	require.NoError(t, err)
+	var ack Ack
+	require.NoError(t, json.Unmarshal(data, &ack))
+	return ack
+}
+
+func TestPoll_ProcessesFileAndReturnsAckAndResult(t *testing.T) {
+	svc, store, payer, customer := newTestService(t, domain.UserTypeMerchant)
+	ctx := context.Background()
+	require.NoError(t, store.Write(ctx, customer, FolderInbound, "payroll.json", []byte(validFile)))
+
+	svc.Poll(ctx)
+
+	require.Len(t, payer.calls, 1)
+	assert.Equal(t, customer, payer.calls[0].SenderID.String())
+	assert.Len(t, payer.calls[0].Payments, 2)
+
+	assert.Equal(t, AckAccepted, readAck(t, store, customer, "payroll.json").Status)
+	data, err := store.Read(ctx, customer, FolderOutbound, ResultName("payroll.json"))
+	require.NoError(t, err)
+	var res Result
+	require.NoError(t, json.Unmarshal(data, &res))
+	assert.Equal(t, 2, res.TotalCount)
+	assert.Len(t, res.Successful, 2)
+
+	inbound, _ := store.List(ctx, customer, FolderInbound)
+	archived, _ := store.List(ctx, customer, FolderArchive)
+	assert.Empty(t, inbound)
+	assert.Equal(t, []string{"payroll.json"}, archived)
+
+	// An archived file is never picked up again.
+	svc.Poll(ctx)
+	assert.Len(t, payer.calls, 1)
+}
+
+func TestPoll_RejectsFilesThatFailTheSchema(t *testing.T) {
+	svc, store, payer, customer := newTestService(t, domain.UserTypeMerchant)
+	ctx := context.Background()
+	require.NoError(t, store.Write(ctx, customer, FolderInbound, "empty.json", []byte(`{"payments":[]}`)))
+	require.NoError(t, store.Write(ctx, customer, FolderInbound, "extra.json", []byte(`{"payments":[],"pay_all":true}`)))
+
+	svc.Poll(ctx)
+
+	assert.Empty(t, payer.calls)
+	for _, name := range []string{"empty.json", "extra.json"} {
+		ack := readAck(t, store, customer, name)
+		assert.Equal(t, AckRejected, ack.Status, name)
+		assert.NotEmpty(t, ack.Errors, name)
+		_, err := store.Read(ctx, customer, FolderOutbound, ResultName(name))
+		assert.ErrorIs(t, err, ErrFileNotFound, name)
+	}
+}
+
+func TestPoll_RejectsDropsNotOwnedByABusiness(t *testing.T) {
+	svc, store, payer, customer := newTestService(t, domain.UserTypeIndividual)
+	ctx := context.Background()
+	require.NoError(t, store.Write(ctx, customer, FolderInbound, "payroll.json", []byte(validFile)))
+	require.NoError(t, store.Write(ctx, "not-a-user", FolderInbound, "payroll.json", []byte(validFile)))
+
+	svc.Poll(ctx)
+
+	assert.Empty(t, payer.calls)
+	assert.Equal(t, AckRejected, readAck(t, store, customer, "payroll.json").Status)
+	assert.Equal(t, AckRejected, readAck(t, store, "not-a-user", "payroll.json").Status)
+}
+
+// memFiles is a FileRepository enforcing one accepted file per hash.
+type memFiles struct {
+	files []*domain.BulkFile
+}
+
+func (m *memFiles) CreateBulkFile(ctx context.Context, f *domain.BulkFile) error {
+	for _, o := range m.files {
+		if o.UserID == f.UserID && o.SHA256 == f.SHA256 && o.Status != domain.BulkFileStatusRejected && f.Status != domain.BulkFileStatusRejected {
+			return kyderrors.ErrDuplicateBulkFile
+		}
+	}
+	m.files = append(m.files, f)
+	return nil
+}
+
+func (m *memFiles) CompleteBulkFile(ctx context.Context, id uuid.UUID, result domain.JSONPayload, processedAt time.Time) error {
+	for _, f := range m.files {
+		if f.ID == id {
+			f.Status = domain.BulkFileStatusProcessed
+			f.Result = result
+			f.ProcessedAt = &processedAt
+		}
+	}
+	return nil
+}
+
+func (m *memFiles) GetBulkFile(ctx context.Context, id uuid.UUID) (*domain.BulkFile, error) {
+	for _, f := range m.files {
+		if f.ID == id {
+			return f, nil
+		}
+	}
+	return nil, kyderrors.ErrBulkFileNotFound
+}
+
+func (m *memFiles) ListBulkFiles(ctx context.Context, userID *uuid.UUID, limit, offset int) ([]domain.BulkFile, int, error) {
+	return nil, 0, nil
+}
+
+func TestPoll_KeepsOriginalAndRejectsResentFile(t *testing.T) {
+	svc, store, payer, customer := newTestService(t, domain.UserTypeMerchant)
+	files := &memFiles{}
+	svc.WithFiles(files)
+	ctx := context.Background()
+	csvFile := "H,KYDBULK,1,PAYROLL-05\nD,6f1c1a52-63a4-4d1e-9d6f-3f4f1c2a9b10,150.00,MWK,,Salary\nT,1,150.00\n"
+
+	require.NoError(t, store.Write(ctx, customer, FolderInbound, "payroll.csv", []byte(csvFile)))
+	svc.Poll(ctx)
+	require.NoError(t, store.Write(ctx, customer, FolderInbound, "payroll-again.csv", []byte(csvFile)))
+	svc.Poll(ctx)
+
+	require.Len(t, payer.calls, 1, "the same file must not be paid twice")
+	require.Len(t, files.files, 1)
+	kept := files.files[0]
+	assert.Equal(t, csvFile, string(kept.Content))
+	assert.Equal(t, "csv", kept.Format)
+	assert.Equal(t, "1", kept.FormatVersion)
+	assert.Equal(t, "PAYROLL-05", kept.Reference)
+	assert.Equal(t, domain.BulkFileStatusProcessed, kept.Status)
+	assert.NotEmpty(t, kept.Result)
+
+	first := readAck(t, store, customer, "payroll.csv")
+	assert.Equal(t, AckAccepted, first.Status)
+	assert.Equal(t, kept.SHA256, first.SHA256)
+	again := readAck(t, store, customer, "payroll-again.csv")
+	assert.Equal(t, AckRejected, again.Status)
+	assert.Equal(t, kept.SHA256, again.SHA256)
+}
+
+func TestPoll_SkipsUploadsInProgress(t *testing.T) {
+	svc, store, payer, customer := newTestService(t, domain.UserTypeMerchant)
+	ctx := context.Background()
+	require.NoError(t, store.Write(ctx, customer, FolderInbound, "payroll.json.filepart", []byte(validFile[:40])))
+
+	svc.Poll(ctx)
+
+	assert.Empty(t, payer.calls)
+	inbound, _ := store.List(ctx, customer, FolderInbound)
+	assert.Equal(t, []string{"payroll.json.filepart"}, inbound)
+}
+
+func TestDirStore_RejectsPathsOutsideTheDrop(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+	ctx := context.Background()
+	assert.Error(t, store.Write(ctx, "..", FolderInbound, "x.json", nil))
+	assert.Error(t, store.Write(ctx, "acme", FolderInbound, "../x.json", nil))
+}
+
+// fakeS3 is an in-memory bucket answering the calls S3Store makes.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	auth    []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	key := strings.TrimPrefix(r.URL.Path, "/bucket")
+	key = strings.TrimPrefix(key, "/")
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		prefix := r.URL.Query().Get("prefix")
+		type entry struct {
+			Key string `xml:"Key"`
+		}
+		type common struct {
+			Prefix string `xml:"Prefix"`
+		}
+		var res struct {
+			XMLName        xml.Name `xml:"ListBucketResult"`
+			Contents       []entry  `xml:"Contents"`
+			CommonPrefixes []common `xml:"CommonPrefixes"`
+		}
+		seen := map[string]bool{}
+		var keys []string
+		for k := range f.objects {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			rest := strings.TrimPrefix(k, prefix)
+			if i := strings.Index(rest, "/"); i >= 0 {
+				p := prefix + rest[:i+1]
+				if !seen[p] {
+					seen[p] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, common{p})
+				}
+				continue
+			}
+			res.Contents = append(res.Contents, entry{k})
+		}
+		xml.NewEncoder(w).Encode(res)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(strings.TrimPrefix(src, "/bucket/"))
+			f.objects[key] = f.objects[src]
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store_RoundTrip(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	store := NewS3Store(S3Config{Endpoint: srv.URL, Region: "us-east-1", Bucket: "bucket", Prefix: "bulk", AccessKey: "AKID", SecretKey: "secret", Timeout: time.Second})
+	ctx := context.Background()
+
+	require.NoError(t, store.Write(ctx, "acme", FolderInbound, "pay roll.json", []byte(validFile)))
+	customers, err := store.Customers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme"}, customers)
+
+	names, err := store.List(ctx, "acme", FolderInbound)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pay roll.json"}, names)
+
+	require.NoError(t, store.Move(ctx, "acme", FolderInbound, FolderArchive, "pay roll.json"))
+	data, err := store.Read(ctx, "acme", FolderArchive, "pay roll.json")
+	require.NoError(t, err)
+	assert.Equal(t, validFile, string(data))
+	_, err = store.Read(ctx, "acme", FolderInbound, "pay roll.json")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	for _, a := range fake.auth {
+		assert.True(t, strings.HasPrefix(a, "AWS4-HMAC-SHA256 Credential=AKID/"), a)
+	}
+}
@@ -0,0 +1,303 @@
+package filechannel
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config addresses a bucket through the S3 API. Endpoint is the service
+// URL, e.g. https://s3.eu-west-1.amazonaws.com; buckets are addressed
+// path-style so that S3-compatible stores work too.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Timeout   time.Duration
+}
+
+// S3Store keeps drops in a bucket under <prefix><customer>/<folder>/<file>.
+type S3Store struct {
+	cfg    S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3Store(cfg S3Config) *S3Store {
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &S3Store{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}, now: time.Now}
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list pages through the keys and common prefixes one level below prefix.
+func (s *S3Store) list(ctx context.Context, prefix string) (keys, prefixes []string, err error) {
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", prefix)
+		q.Set("delimiter", "/")
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		body, err := s.do(ctx, http.MethodGet, "", q, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var res listBucketResult
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, nil, fmt.Errorf("decode bucket listing: %w", err)
+		}
+		for _, c := range res.Contents {
+			keys = append(keys, c.Key)
+		}
+		for _, p := range res.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return keys, prefixes, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+func (s *S3Store) Customers(ctx context.Context) ([]string, error) {
+	_, prefixes, err := s.list(ctx, s.cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		if c := strings.TrimSuffix(strings.TrimPrefix(p, s.cfg.Prefix), "/"); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (s *S3Store) List(ctx context.Context, customer, folder string) ([]string, error) {
+	dir, err := s.key(customer, folder, "")
+	if err != nil {
+		return nil, err
+	}
+	keys, _, err := s.list(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if name := strings.TrimPrefix(k, dir); name != "" {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (s *S3Store) Read(ctx context.Context, customer, folder, name string) ([]byte, error) {
+	k, err := s.key(customer, folder, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(ctx, http.MethodGet, k, nil, nil, nil)
+}
+
+func (s *S3Store) Write(ctx context.Context, customer, folder, name string, data []byte) error {
+	k, err := s.key(customer, folder, name)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(ctx, http.MethodPut, k, nil, nil, data)
+	return err
+}
+
+// Move copies the object server-side and deletes the original; S3 has no
+// rename.
+func (s *S3Store) Move(ctx context.Context, customer, from, to, name string) error {
+	src, err := s.key(customer, from, name)
+	if err != nil {
+		return err
+	}
+	dst, err := s.key(customer, to, name)
+	if err != nil {
+		return err
+	}
+	h := http.Header{}
+	h.Set("X-Amz-Copy-Source", "/"+s.cfg.Bucket+"/"+escapePath(src))
+	if _, err := s.do(ctx, http.MethodPut, dst, nil, h, nil); err != nil {
+		return err
+	}
+	_, err = s.do(ctx, http.MethodDelete, src, nil, nil, nil)
+	return err
+}
+
+func (s *S3Store) key(customer, folder, name string) (string, error) {
+	for _, part := range []string{customer, folder, name} {
+		if part == "." || part == ".." || strings.Contains(part, "/") {
+			return "", fmt.Errorf("invalid path element %q", part)
+		}
+	}
+	if customer == "" || folder == "" {
+		return "", fmt.Errorf("customer and folder are required")
+	}
+	return s.cfg.Prefix + customer + "/" + folder + "/" + name, nil
+}
+
+// do sends a signed request for key (or the bucket itself when key is
+// empty) and returns the response body.
+func (s *S3Store) do(ctx context.Context, method, key string, q url.Values, h http.Header, body []byte) ([]byte, error) {
+	u := s.cfg.Endpoint + "/" + s.cfg.Bucket
+	if key != "" {
+		u += "/" + escapePath(key)
+	}
+	if len(q) > 0 {
+		u += "?" + canonicalQuery(q)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	s.sign(req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrFileNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header.
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := []string{"host"}
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") {
+			names = append(names, lk)
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, n := range names {
+		canonHeaders.WriteString(n + ":" + headers[n] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsEscape percent-encodes everything but the unreserved characters, as
+// SigV4 requires; url.QueryEscape would write spaces as '+'.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func escapePath(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = awsEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,348 @@
+// Package filechannel takes bulk payments from corporates that can only
+// exchange files. It picks up payment files from each customer's drop,
+// validates them against the bulk file formats, runs them through the bulk
+// engine and returns acknowledgment and result files to the same drop.
+package filechannel
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/bulkfile"
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	"kyd/pkg/config"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// BulkPayer is the bulk payment engine.
+type BulkPayer interface {
+	BulkPayment(ctx context.Context, req *payment.BulkPaymentRequest) (*payment.BulkPaymentResult, error)
+}
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// FileRepository keeps every file received, byte for byte, with its hash.
+type FileRepository interface {
+	// CreateBulkFile returns ErrDuplicateBulkFile when the customer has
+	// already sent an accepted file with the same content.
+	CreateBulkFile(ctx context.Context, f *domain.BulkFile) error
+	CompleteBulkFile(ctx context.Context, id uuid.UUID, result domain.JSONPayload, processedAt time.Time) error
+	GetBulkFile(ctx context.Context, id uuid.UUID) (*domain.BulkFile, error)
+	// ListBulkFiles returns the customer's files, or every file when userID
+	// is nil, newest first and without their content.
+	ListBulkFiles(ctx context.Context, userID *uuid.UUID, limit, offset int) ([]domain.BulkFile, int, error)
+}
+
+// Ack statuses.
+const (
+	AckAccepted = "accepted"
+	AckRejected = "rejected"
+)
+
+// Ack is written to the outbound folder as soon as a file has been checked,
+// before any of its payments are made. A rejected file lists every problem
+// found, by line.
+type Ack struct {
+	File          string               `json:"file"`
+	Format        bulkfile.Format      `json:"format,omitempty"`
+	FormatVersion string               `json:"format_version,omitempty"`
+	Reference     string               `json:"reference,omitempty"`
+	SHA256        string               `json:"sha256"`
+	Status        string               `json:"status"`
+	PaymentCount  int                  `json:"payment_count"`
+	Errors        []bulkfile.LineError `json:"errors,omitempty"`
+	ReceivedAt    time.Time            `json:"received_at"`
+}
+
+// Result is written to the outbound folder once every payment in an
+// accepted file has been attempted.
+type Result struct {
+	File        string    `json:"file"`
+	ProcessedAt time.Time `json:"processed_at"`
+	*payment.BulkPaymentResult
+}
+
+// AckName and ResultName name the files returned for an uploaded file.
+func AckName(file string) string    { return file + ".ack.json" }
+func ResultName(file string) string { return file + ".result.json" }
+
+type Service struct {
+	store    Store
+	payer    BulkPayer
+	users    UserRepository
+	files    FileRepository
+	logger   logger.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(store Store, payer BulkPayer, users UserRepository, interval time.Duration, log logger.Logger) *Service {
+	return &Service{
+		store:    store,
+		payer:    payer,
+		users:    users,
+		logger:   log,
+		interval: interval,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// WithFiles keeps every file received, which also stops the same file from
+// being paid twice.
+func (s *Service) WithFiles(repo FileRepository) *Service {
+	s.files = repo
+	return s
+}
+
+// Get returns a received file with its content.
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.BulkFile, error) {
+	if s.files == nil {
+		return nil, kyderrors.ErrBulkFileNotFound
+	}
+	return s.files.GetBulkFile(ctx, id)
+}
+
+func (s *Service) List(ctx context.Context, userID *uuid.UUID, limit, offset int) ([]domain.BulkFile, int, error) {
+	if s.files == nil {
+		return []domain.BulkFile{}, 0, nil
+	}
+	return s.files.ListBulkFiles(ctx, userID, limit, offset)
+}
+
+// FromConfig builds the store the channel reads from: "s3" for a bucket,
+// anything else for a local directory.
+func FromConfig(cfg config.FileChannelConfig) Store {
+	if strings.EqualFold(cfg.Backend, "s3") {
+		return NewS3Store(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			Prefix:    cfg.S3Prefix,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Timeout:   cfg.Timeout,
+		})
+	}
+	return NewDirStore(cfg.Dir)
+}
+
+// Start polls the drops until Stop is called.
+func (s *Service) Start() {
+	s.reportStranded(context.Background())
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Poll(context.Background())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("File channel started", map[string]interface{}{"interval": s.interval.String()})
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Poll processes every file waiting in the customers' inbound folders.
+func (s *Service) Poll(ctx context.Context) {
+	customers, err := s.store.Customers(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list file channel customers", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	for _, c := range customers {
+		names, err := s.store.List(ctx, c, FolderInbound)
+		if err != nil {
+			s.logger.Error("Failed to list inbound files", map[string]interface{}{"error": err.Error(), "customer": c})
+			continue
+		}
+		for _, name := range names {
+			if !isPaymentFile(name) {
+				continue
+			}
+			if err := s.process(ctx, c, name); err != nil {
+				s.logger.Error("Failed to process payment file", map[string]interface{}{"error": err.Error(), "customer": c, "file": name})
+			}
+		}
+	}
+}
+
+// isPaymentFile skips hidden files and the temporary names SFTP clients
+// upload under before renaming a finished file into place.
+func isPaymentFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, suffix := range []string{".part", ".partial", ".filepart", ".tmp"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// process claims a file by moving it to the processing folder, so that no
+// other instance picks it up, and only archives it once its result is
+// written. A file is never processed twice: one stranded in processing by
+// a crash is left for an operator to reconcile, because some of its
+// payments may already have been made.
+func (s *Service) process(ctx context.Context, customer, name string) error {
+	if err := s.store.Move(ctx, customer, FolderInbound, FolderProcessing, name); err != nil {
+		if err == ErrFileNotFound {
+			return nil // claimed by another instance
+		}
+		return fmt.Errorf("claim: %w", err)
+	}
+	received := s.now()
+	data, err := s.store.Read(ctx, customer, FolderProcessing, name)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	ack := Ack{File: name, SHA256: hex.EncodeToString(sum[:]), Status: AckAccepted, ReceivedAt: received}
+
+	senderID, ownerErr := s.owner(ctx, customer)
+	file, errs := bulkfile.Parse(name, data)
+	if ownerErr != nil {
+		errs = append([]bulkfile.LineError{{Message: ownerErr.Error()}}, errs...)
+	}
+	if file != nil {
+		ack.Format = file.Format
+		ack.FormatVersion = file.Version
+		ack.Reference = file.Reference
+		ack.PaymentCount = len(file.Payments)
+	}
+	if errs != nil {
+		ack.Status = AckRejected
+	}
+
+	var record *domain.BulkFile
+	if ownerErr == nil && s.files != nil {
+		record = &domain.BulkFile{
+			ID:            uuid.New(),
+			UserID:        senderID,
+			FileName:      name,
+			Format:        string(ack.Format),
+			FormatVersion: ack.FormatVersion,
+			Reference:     ack.Reference,
+			SHA256:        ack.SHA256,
+			Size:          int64(len(data)),
+			Content:       data,
+			Status:        domain.BulkFileStatusAccepted,
+			PaymentCount:  ack.PaymentCount,
+			ReceivedAt:    received,
+		}
+		if errs != nil {
+			record.Status = domain.BulkFileStatusRejected
+			record.Errors, _ = json.Marshal(errs)
+		}
+		if err := s.files.CreateBulkFile(ctx, record); err != nil {
+			if !errors.Is(err, kyderrors.ErrDuplicateBulkFile) {
+				// Put the file back for the next poll rather than pay
+				// without a record of what was instructed.
+				if merr := s.store.Move(ctx, customer, FolderProcessing, FolderInbound, name); merr != nil {
+					s.logger.Error("Failed to return payment file to inbound", map[string]interface{}{"error": merr.Error(), "customer": customer, "file": name})
+				}
+				return fmt.Errorf("record file: %w", err)
+			}
+			ack.Status = AckRejected
+			errs = append(errs, bulkfile.LineError{Message: "a file with the same content was already accepted; it will not be paid again"})
+			record = nil
+		}
+	}
+	ack.Errors = errs
+	if err := s.writeJSON(ctx, customer, AckName(name), ack); err != nil {
+		return fmt.Errorf("write ack: %w", err)
+	}
+
+	if ack.Status == AckAccepted {
+		res, err := s.payer.BulkPayment(ctx, &payment.BulkPaymentRequest{SenderID: senderID, Payments: file.Payments})
+		if err != nil {
+			return fmt.Errorf("bulk payment: %w", err)
+		}
+		processed := s.now()
+		if record != nil {
+			doc, _ := json.Marshal(res)
+			if err := s.files.CompleteBulkFile(ctx, record.ID, doc, processed); err != nil {
+				s.logger.Error("Failed to record bulk file result", map[string]interface{}{"error": err.Error(), "file_id": record.ID})
+			}
+		}
+		if err := s.writeJSON(ctx, customer, ResultName(name), Result{File: name, ProcessedAt: processed, BulkPaymentResult: res}); err != nil {
+			return fmt.Errorf("write result: %w", err)
+		}
+		s.logger.Info("Processed payment file", map[string]interface{}{
+			"customer":   customer,
+			"file":       name,
+			"format":     ack.Format,
+			"successful": len(res.Successful),
+			"failed":     len(res.Failed),
+		})
+	} else {
+		s.logger.Warn("Rejected payment file", map[string]interface{}{"customer": customer, "file": name, "errors": len(ack.Errors)})
+	}
+
+	if err := s.store.Move(ctx, customer, FolderProcessing, FolderArchive, name); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
+
+// owner returns the active business customer a drop belongs to.
+func (s *Service) owner(ctx context.Context, customer string) (uuid.UUID, error) {
+	senderID, err := uuid.Parse(customer)
+	if err != nil {
+		return uuid.Nil, errors.New("drop is not linked to a customer")
+	}
+	sender, err := s.users.FindByID(ctx, senderID)
+	if err != nil || sender == nil || !sender.IsActive || sender.UserType != domain.UserTypeMerchant {
+		return uuid.Nil, errors.New("drop is not linked to an active business customer")
+	}
+	return senderID, nil
+}
+
+func (s *Service) writeJSON(ctx context.Context, customer, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.store.Write(ctx, customer, FolderOutbound, name, data)
+}
+
+// reportStranded logs files left in processing by an earlier run.
+func (s *Service) reportStranded(ctx context.Context) {
+	customers, err := s.store.Customers(ctx)
+	if err != nil {
+		return
+	}
+	for _, c := range customers {
+		names, err := s.store.List(ctx, c, FolderProcessing)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			s.logger.Warn("Payment file was interrupted mid-processing and needs reconciling", map[string]interface{}{"customer": c, "file": name})
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package filechannel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Folders inside each customer's drop. Customers upload to FolderInbound and
+// collect acknowledgment and result files from FolderOutbound; the others
+// are kept by the channel.
+const (
+	FolderInbound    = "inbound"
+	FolderProcessing = "processing"
+	FolderArchive    = "archive"
+	FolderOutbound   = "outbound"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// Store is where customers drop payment files: a directory per customer on
+// the SFTP server, or a prefix per customer in a bucket. A customer is named
+// by the user ID of the corporate sending the payments.
+type Store interface {
+	// Customers lists the customers that have a drop.
+	Customers(ctx context.Context) ([]string, error)
+	// List returns the names of the files in one of a customer's folders,
+	// sorted so that files are processed in upload-name order.
+	List(ctx context.Context, customer, folder string) ([]string, error)
+	Read(ctx context.Context, customer, folder, name string) ([]byte, error)
+	Write(ctx context.Context, customer, folder, name string, data []byte) error
+	// Move moves a file between a customer's folders, replacing any file of
+	// the same name.
+	Move(ctx context.Context, customer, from, to, name string) error
+}
+
+// DirStore keeps drops under a local root, laid out as
+// <root>/<customer>/<folder>/<file>. It serves SFTP drops when the SFTP
+// server chroots each customer's account into <root>/<customer>.
+type DirStore struct {
+	root string
+}
+
+func NewDirStore(root string) *DirStore {
+	return &DirStore{root: root}
+}
+
+func (d *DirStore) Customers(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			out = append(out, e.Name())
+		}
+	}
+	return out, nil
+}
+
+func (d *DirStore) List(ctx context.Context, customer, folder string) ([]string, error) {
+	dir, err := d.path(customer, folder, "")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			out = append(out, e.Name())
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (d *DirStore) Read(ctx context.Context, customer, folder, name string) ([]byte, error) {
+	p, err := d.path(customer, folder, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrFileNotFound
+	}
+	return data, err
+}
+
+// Write writes to a temporary name and renames it into place, so a customer
+// polling the folder never collects a partial file.
+func (d *DirStore) Write(ctx context.Context, customer, folder, name string, data []byte) error {
+	p, err := d.path(customer, folder, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(p), "."+name+".part")
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (d *DirStore) Move(ctx context.Context, customer, from, to, name string) error {
+	src, err := d.path(customer, from, name)
+	if err != nil {
+		return err
+	}
+	dst, err := d.path(customer, to, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if os.IsNotExist(err) {
+			return ErrFileNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// path joins the parts under the root, refusing any that would climb out of
+// the customer's drop.
+func (d *DirStore) path(customer, folder, name string) (string, error) {
+	for _, part := range []string{customer, folder, name} {
+		if part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid path element %q", part)
+		}
+	}
+	if customer == "" || folder == "" {
+		return "", fmt.Errorf("customer and folder are required")
+	}
+	return filepath.Join(d.root, customer, folder, name), nil
+}
@@ -0,0 +1,452 @@
+package forex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/tracing"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// healthWindow and healthPeriod bound the calls a provider's score and
+	// error rate are taken over: its most recent, in the last few minutes.
+	// A demoted provider is not called while the others are healthy, so
+	// its failures age out and it gets another chance.
+	healthWindow = 20
+	healthPeriod = 5 * time.Minute
+
+	// minProviderScore is the score below which a provider with at least
+	// minScoredCalls recent calls is only tried once the healthier ones
+	// have failed.
+	minProviderScore = 0.5
+	minScoredCalls   = 3
+)
+
+var (
+	errRateStale    = errors.New("rate is stale")
+	errRateDeviates = errors.New("rate deviates from the last good rate")
+)
+
+// CircuitState is whether a provider is being called.
+type CircuitState string
+
+const (
+	// CircuitClosed providers are called as usual.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen providers failed too often in a row and are skipped
+	// until their cooldown ends.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen providers have one trial call in flight, which
+	// closes the circuit again on success and reopens it on failure.
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// FailoverConfig tunes provider failover and rate sanity checks.
+type FailoverConfig struct {
+	// FailureThreshold failures in a row open a provider's circuit for
+	// Cooldown.
+	FailureThreshold int
+	Cooldown         time.Duration
+	// MaxRateAge is the oldest quote a provider may return, and how long
+	// one may go without a good rate before it is reported stale.
+	MaxRateAge time.Duration
+	// MaxDeviation is the largest relative move from a pair's last good
+	// rate accepted from one provider alone, e.g. 0.1 for 10%.
+	MaxDeviation float64
+}
+
+// DefaultFailoverConfig returns the failover settings used unless
+// configured otherwise.
+func DefaultFailoverConfig() FailoverConfig {
+	return FailoverConfig{FailureThreshold: 3, Cooldown: time.Minute, MaxRateAge: 15 * time.Minute, MaxDeviation: 0.1}
+}
+
+// FailoverConfigFromConfig builds failover settings from service
+// configuration; unset values keep their defaults.
+func FailoverConfigFromConfig(cfg config.ForexConfig) FailoverConfig {
+	f := DefaultFailoverConfig()
+	if cfg.ProviderFailureThreshold > 0 {
+		f.FailureThreshold = cfg.ProviderFailureThreshold
+	}
+	if cfg.ProviderCooldown > 0 {
+		f.Cooldown = cfg.ProviderCooldown
+	}
+	if cfg.ProviderMaxRateAge > 0 {
+		f.MaxRateAge = cfg.ProviderMaxRateAge
+	}
+	if cfg.MaxRateDeviation > 0 {
+		f.MaxDeviation = cfg.MaxRateDeviation
+	}
+	return f
+}
+
+// WithFailover overrides the default failover settings.
+func (s *Service) WithFailover(cfg FailoverConfig) *Service {
+	s.failover.mu.Lock()
+	s.failover.cfg = cfg
+	s.failover.mu.Unlock()
+	return s
+}
+
+// ProviderStatus is a provider's health as seen by this instance.
+type ProviderStatus struct {
+	Name string `json:"name"`
+	// Priority is the configured order, 1 being tried first while healthy.
+	Priority int          `json:"priority"`
+	State    CircuitState `json:"state"`
+	// Score is the share of recent calls that returned a usable rate, 1
+	// when there were none. Providers scoring under 0.5 over at least 3
+	// recent calls are tried after the others.
+	Score               float64 `json:"score"`
+	ErrorRate           float64 `json:"error_rate"`
+	RecentCalls         int     `json:"recent_calls"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	// RejectedRates counts rates refused as stale or implausible.
+	RejectedRates int64      `json:"rejected_rates"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	// RetryAt is when an open circuit lets a trial call through.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+	// Stale is set when the provider has not returned a good rate for
+	// MaxRateAge.
+	Stale bool `json:"stale"`
+}
+
+// ProviderStatus reports each provider's health, in priority order.
+func (s *Service) ProviderStatus() []ProviderStatus {
+	f := s.failover
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	out := make([]ProviderStatus, 0, len(s.providers))
+	for i, p := range s.providers {
+		h := f.health(i)
+		score, calls := h.score(now)
+		st := ProviderStatus{
+			Name:                p.Name(),
+			Priority:            i + 1,
+			State:               h.state,
+			Score:               score,
+			RecentCalls:         calls,
+			ConsecutiveFailures: h.consecutive,
+			RejectedRates:       h.rejected,
+			LastError:           h.lastError,
+			Stale:               h.lastSuccess.IsZero() || now.Sub(h.lastSuccess) > f.cfg.MaxRateAge,
+		}
+		if calls > 0 {
+			st.ErrorRate = 1 - score
+		}
+		if !h.lastSuccess.IsZero() {
+			t := h.lastSuccess
+			st.LastSuccessAt = &t
+		}
+		if !h.lastFailure.IsZero() {
+			t := h.lastFailure
+			st.LastFailureAt = &t
+		}
+		if h.state != CircuitClosed {
+			t := h.openedAt.Add(f.cfg.Cooldown)
+			st.RetryAt = &t
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// failover tracks the health of the service's providers, by position, and
+// the last good rate of each pair.
+type failover struct {
+	mu        sync.Mutex
+	cfg       FailoverConfig
+	now       func() time.Time
+	providers []*providerHealth
+	lastGood  map[string]decimal.Decimal
+}
+
+type providerHealth struct {
+	outcomes    []outcome // most recent last, at most healthWindow
+	consecutive int
+	state       CircuitState
+	openedAt    time.Time
+	rejected    int64
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   string
+}
+
+func newFailover() *failover {
+	return &failover{cfg: DefaultFailoverConfig(), now: time.Now, lastGood: make(map[string]decimal.Decimal)}
+}
+
+// health returns the record of the i'th provider. f.mu must be held.
+func (f *failover) health(i int) *providerHealth {
+	for len(f.providers) <= i {
+		f.providers = append(f.providers, &providerHealth{state: CircuitClosed})
+	}
+	return f.providers[i]
+}
+
+type outcome struct {
+	at time.Time
+	ok bool
+}
+
+// score returns the share of the calls within healthPeriod of now that
+// succeeded, 1 when there were none, and how many there were.
+func (h *providerHealth) score(now time.Time) (float64, int) {
+	calls, ok := 0, 0
+	for _, o := range h.outcomes {
+		if now.Sub(o.at) > healthPeriod {
+			continue
+		}
+		calls++
+		if o.ok {
+			ok++
+		}
+	}
+	if calls == 0 {
+		return 1, 0
+	}
+	return float64(ok) / float64(calls), calls
+}
+
+// demoted reports whether the provider has failed too many recent calls to
+// be tried first.
+func (h *providerHealth) demoted(now time.Time) bool {
+	score, calls := h.score(now)
+	return calls >= minScoredCalls && score < minProviderScore
+}
+
+func (h *providerHealth) observe(ok bool, at time.Time) {
+	h.outcomes = append(h.outcomes, outcome{at: at, ok: ok})
+	if len(h.outcomes) > healthWindow {
+		h.outcomes = h.outcomes[1:]
+	}
+}
+
+// order returns the providers to try, by position: those not demoted in
+// priority order, then the demoted ones. An open circuit due its trial call
+// keeps its place, so a recovered provider is found out.
+func (f *failover) order(n int) []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	last := make([]bool, n)
+	for i := range last {
+		h := f.health(i)
+		due := h.state == CircuitOpen && !now.Before(h.openedAt.Add(f.cfg.Cooldown))
+		last[i] = h.demoted(now) && !due
+	}
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return !last[idx[a]] && last[idx[b]] })
+	return idx
+}
+
+// acquire reports whether the i'th provider may be called now. An open
+// circuit past its cooldown lets one trial call through.
+func (f *failover) acquire(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := f.health(i)
+	switch h.state {
+	case CircuitOpen:
+		if f.now().Before(h.openedAt.Add(f.cfg.Cooldown)) {
+			return false
+		}
+		h.state = CircuitHalfOpen
+		return true
+	case CircuitHalfOpen:
+		return false
+	}
+	return true
+}
+
+// succeed records a usable rate from the i'th provider, closing its
+// circuit. A successful trial call clears its record, as it has recovered.
+func (f *failover) succeed(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := f.health(i)
+	now := f.now()
+	if h.state == CircuitHalfOpen {
+		h.outcomes = nil
+	}
+	h.observe(true, now)
+	h.consecutive, h.state, h.lastSuccess = 0, CircuitClosed, now
+}
+
+// fail records an error or refused rate from the i'th provider, opening its
+// circuit after FailureThreshold in a row, or at once after a failed trial
+// call.
+func (f *failover) fail(i int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := f.health(i)
+	now := f.now()
+	h.observe(false, now)
+	h.consecutive++
+	h.lastFailure, h.lastError = now, err.Error()
+	if errors.Is(err, errRateStale) || errors.Is(err, errRateDeviates) {
+		h.rejected++
+	}
+	if h.state == CircuitHalfOpen || h.consecutive >= f.cfg.FailureThreshold {
+		h.state, h.openedAt = CircuitOpen, now
+	}
+}
+
+// check refuses a rate quoted longer than MaxRateAge ago, or one that moved
+// more than MaxDeviation from last, when last is known.
+func (f *failover) check(rate *domain.ExchangeRate, last decimal.Decimal) error {
+	f.mu.Lock()
+	cfg, now := f.cfg, f.now()
+	f.mu.Unlock()
+	if !rate.Rate.IsPositive() {
+		return fmt.Errorf("%w: %s is not positive", errRateDeviates, rate.Rate)
+	}
+	quoted := rate.LastUpdated
+	if quoted.IsZero() {
+		quoted = rate.ValidFrom
+	}
+	if !quoted.IsZero() && now.Sub(quoted) > cfg.MaxRateAge {
+		return fmt.Errorf("%w: quoted at %s", errRateStale, quoted.UTC().Format(time.RFC3339))
+	}
+	if last.IsPositive() && deviation(rate.Rate, last) > cfg.MaxDeviation {
+		return fmt.Errorf("%w: %s against %s", errRateDeviates, rate.Rate, last)
+	}
+	return nil
+}
+
+func (f *failover) lastGoodRate(key string) (decimal.Decimal, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, ok := f.lastGood[key]
+	return r, ok
+}
+
+func (f *failover) setLastGood(key string, rate decimal.Decimal) {
+	f.mu.Lock()
+	f.lastGood[key] = rate
+	f.mu.Unlock()
+}
+
+func (f *failover) maxDeviation() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cfg.MaxDeviation
+}
+
+// deviation is the relative move from last to rate.
+func deviation(rate, last decimal.Decimal) float64 {
+	d, _ := rate.Sub(last).Abs().Div(last).Float64()
+	return d
+}
+
+// abandon puts back a trial call that ended with the caller's context,
+// which says nothing of the provider's health.
+func (f *failover) abandon(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if h := f.health(i); h.state == CircuitHalfOpen {
+		h.state = CircuitOpen
+	}
+}
+
+// fetchRate asks the providers for a pair's rate, healthiest first,
+// skipping open circuits. A rate that moved more than MaxDeviation from the
+// pair's last good rate is only accepted once a second provider quotes
+// within MaxDeviation of it, so a real market move gets through but one
+// provider's bad quote does not.
+func (s *Service) fetchRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
+	key := rateKey(from, to)
+	last, ok := s.failover.lastGoodRate(key)
+	if !ok {
+		if stored, err := s.repo.GetLatestRate(ctx, from, to); err == nil && stored != nil {
+			last = stored.Rate
+		}
+	}
+
+	// outliers are the providers whose rate deviated, waiting for another
+	// provider to corroborate or contradict them.
+	type outlier struct {
+		provider int
+		rate     decimal.Decimal
+		err      error
+	}
+	var outliers []outlier
+	defer func() {
+		for _, o := range outliers {
+			s.failProvider(o.provider, from, to, o.err)
+		}
+	}()
+
+	for _, i := range s.failover.order(len(s.providers)) {
+		if !s.failover.acquire(i) {
+			continue
+		}
+		provider := s.providers[i]
+		pctx, span := tracing.Start(ctx, "forex.provider "+provider.Name())
+		rate, err := provider.GetRate(pctx, from, to)
+		tracing.End(span, err)
+		if err != nil && ctx.Err() != nil {
+			s.failover.abandon(i)
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			err = s.failover.check(rate, last)
+		}
+		if errors.Is(err, errRateDeviates) && rate.Rate.IsPositive() {
+			corroborated := -1
+			for n, o := range outliers {
+				if deviation(rate.Rate, o.rate) <= s.failover.maxDeviation() {
+					corroborated = n
+					break
+				}
+			}
+			if corroborated < 0 {
+				outliers = append(outliers, outlier{provider: i, rate: rate.Rate, err: err})
+				continue
+			}
+			o := outliers[corroborated]
+			outliers = append(outliers[:corroborated], outliers[corroborated+1:]...)
+			s.failover.succeed(o.provider)
+			s.logger.Warn("Accepting corroborated rate move", map[string]interface{}{
+				"pair":      key,
+				"last":      last.String(),
+				"rate":      rate.Rate.String(),
+				"providers": []string{s.providers[o.provider].Name(), provider.Name()},
+			})
+			err = nil
+		}
+		if err != nil {
+			s.failProvider(i, from, to, err)
+			continue
+		}
+		s.failover.succeed(i)
+		s.failover.setLastGood(key, rate.Rate)
+		return rate, nil
+	}
+	return nil, pkgerrors.ErrRateNotAvailable
+}
+
+func (s *Service) failProvider(i int, from, to domain.Currency, err error) {
+	s.failover.fail(i, err)
+	s.logger.Warn("Provider failed", map[string]interface{}{
+		"provider": s.providers[i].Name(),
+		"from":     from,
+		"to":       to,
+		"error":    err.Error(),
+	})
+}
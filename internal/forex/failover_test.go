@@ -0,0 +1,180 @@
+package forex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider quotes rate, or fails with err when it is set.
+type scriptedProvider struct {
+	name   string
+	rate   decimal.Decimal
+	quoted time.Time
+	err    error
+	calls  int
+}
+
+func (p *scriptedProvider) Name() string { return p.name }
+
+func (p *scriptedProvider) GetRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &domain.ExchangeRate{BaseCurrency: from, TargetCurrency: to, Rate: p.rate, ValidFrom: p.quoted}, nil
+}
+
+func newFailoverService(last int64, providers ...*scriptedProvider) (*Service, *time.Time) {
+	repo := &fakeRateRepo{latest: map[string]*domain.ExchangeRate{}}
+	if last > 0 {
+		repo.latest["MWK-ZAR"] = testRate(domain.MWK, domain.ZAR, last)
+	}
+	list := make([]RateProvider, len(providers))
+	for i, p := range providers {
+		list[i] = p
+	}
+	s := NewService(repo, nil, list, logger.NewNop()).WithFailover(DefaultFailoverConfig())
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	s.failover.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestFailoverCircuitBreaking(t *testing.T) {
+	ctx := context.Background()
+	primary := &scriptedProvider{name: "primary", err: errors.New("timeout")}
+	backup := &scriptedProvider{name: "backup", rate: decimal.NewFromInt(100)}
+	s, now := newFailoverService(0, primary, backup)
+
+	for i := 0; i < 3; i++ {
+		rate, err := s.fetchRate(ctx, domain.MWK, domain.ZAR)
+		require.NoError(t, err)
+		assert.True(t, rate.Rate.Equal(decimal.NewFromInt(100)))
+	}
+	assert.Equal(t, 3, primary.calls)
+	status := s.ProviderStatus()
+	assert.Equal(t, CircuitOpen, status[0].State)
+	assert.Equal(t, 3, status[0].ConsecutiveFailures)
+	assert.Equal(t, float64(1), status[0].ErrorRate)
+	assert.Equal(t, now.Add(time.Minute), *status[0].RetryAt)
+	assert.Equal(t, CircuitClosed, status[1].State)
+	assert.False(t, status[1].Stale)
+
+	_, err := s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Equal(t, 3, primary.calls, "skipped while open")
+
+	// After the cooldown one trial call goes through; failing it reopens
+	// the circuit at once.
+	*now = now.Add(time.Minute)
+	_, err = s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Equal(t, 4, primary.calls)
+	assert.Equal(t, CircuitOpen, s.ProviderStatus()[0].State)
+
+	// A successful trial closes it with a clean record.
+	*now = now.Add(time.Minute)
+	primary.err, primary.rate = nil, decimal.NewFromInt(101)
+	rate, err := s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(101)), "the trial call")
+	status = s.ProviderStatus()
+	assert.Equal(t, CircuitClosed, status[0].State)
+	assert.Equal(t, float64(1), status[0].Score)
+	assert.Equal(t, 1, status[0].RecentCalls)
+
+	backup.err = errors.New("down")
+	primary.err = errors.New("down")
+	_, err = s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	assert.ErrorIs(t, err, pkgerrors.ErrRateNotAvailable)
+}
+
+func TestFailoverDemotesFlakyProviders(t *testing.T) {
+	ctx := context.Background()
+	primary := &scriptedProvider{name: "primary", rate: decimal.NewFromInt(100)}
+	backup := &scriptedProvider{name: "backup", rate: decimal.NewFromInt(100)}
+	s, now := newFailoverService(0, primary, backup)
+
+	// Failing two calls in three never opens the circuit, but demotes it.
+	for _, fails := range []bool{true, false, true} {
+		primary.err = nil
+		if fails {
+			primary.err = errors.New("timeout")
+		}
+		_, err := s.fetchRate(ctx, domain.MWK, domain.ZAR)
+		require.NoError(t, err)
+	}
+	status := s.ProviderStatus()
+	assert.Equal(t, CircuitClosed, status[0].State)
+	assert.InDelta(t, 1.0/3, status[0].Score, 1e-9)
+	assert.InDelta(t, 2.0/3, status[0].ErrorRate, 1e-9)
+
+	primary.err = nil
+	_, err := s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Equal(t, 3, primary.calls, "the healthier provider is tried first")
+
+	// Once its failures age out it is first again.
+	*now = now.Add(healthPeriod + time.Second)
+	_, err = s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Equal(t, 4, primary.calls)
+}
+
+func TestFailoverRejectsImplausibleRates(t *testing.T) {
+	ctx := context.Background()
+	primary := &scriptedProvider{name: "primary", rate: decimal.NewFromInt(150)}
+	backup := &scriptedProvider{name: "backup", rate: decimal.NewFromInt(104)}
+	s, now := newFailoverService(100, primary, backup)
+
+	rate, err := s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(104)), "50% off the stored rate, and not corroborated")
+	status := s.ProviderStatus()
+	assert.Equal(t, int64(1), status[0].RejectedRates)
+	assert.Contains(t, status[0].LastError, "deviates")
+
+	// The last good rate is now the backup's; a stale quote is refused too.
+	primary.rate = decimal.NewFromInt(105)
+	primary.quoted = now.Add(-time.Hour)
+	rate, err = s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(104)))
+	assert.Equal(t, int64(2), s.ProviderStatus()[0].RejectedRates)
+	assert.Contains(t, s.ProviderStatus()[0].LastError, "stale")
+
+	primary.rate, primary.quoted = decimal.Zero, *now
+	backup.err = errors.New("down")
+	_, err = s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	assert.ErrorIs(t, err, pkgerrors.ErrRateNotAvailable, "a zero rate is never accepted")
+}
+
+func TestFailoverAcceptsCorroboratedMove(t *testing.T) {
+	ctx := context.Background()
+	// A devaluation: every provider quotes well away from the stored rate.
+	primary := &scriptedProvider{name: "primary", rate: decimal.NewFromInt(144)}
+	backup := &scriptedProvider{name: "backup", rate: decimal.NewFromInt(145)}
+	s, _ := newFailoverService(100, primary, backup)
+
+	rate, err := s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(145)))
+	for _, st := range s.ProviderStatus() {
+		assert.Equal(t, int64(0), st.RejectedRates, st.Name)
+		assert.Equal(t, float64(1), st.Score, st.Name)
+	}
+
+	// From then on the new level is the reference.
+	rate, err = s.fetchRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(144)))
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"kyd/internal/domain"
+	"kyd/pkg/tracing"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -24,7 +25,8 @@ type GoogleFinanceProvider struct {
 func NewGoogleFinanceProvider() *GoogleFinanceProvider {
 	return &GoogleFinanceProvider{
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: tracing.Transport(nil),
 		},
 	}
 }
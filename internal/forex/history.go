@@ -0,0 +1,305 @@
+package forex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// maxSeriesBars bounds the bars one series request may span.
+const maxSeriesBars = 1500
+
+var (
+	// ErrInvalidSeries is returned for a rate series request that cannot
+	// be served, such as one spanning too many bars.
+	ErrInvalidSeries = errors.New("invalid rate series request")
+	// ErrNotConverted is returned auditing the rate of a transaction made
+	// in one currency.
+	ErrNotConverted = errors.New("transaction was not converted between currencies")
+	// ErrHistoryDisabled is returned when rate history is not configured.
+	ErrHistoryDisabled = errors.New("rate history is not enabled")
+)
+
+// RateHistoryRepository keeps sampled rates as OHLC bars.
+type RateHistoryRepository interface {
+	RecordSample(ctx context.Context, from, to domain.Currency, rate decimal.Decimal, at time.Time) error
+	Bars(ctx context.Context, from, to domain.Currency, res domain.RateResolution, start, end time.Time) ([]domain.RateBar, error)
+	// BarAt returns nil when no bar covering at is kept.
+	BarAt(ctx context.Context, from, to domain.Currency, res domain.RateResolution, at time.Time) (*domain.RateBar, error)
+	PruneBars(ctx context.Context, res domain.RateResolution, before time.Time) (int, error)
+}
+
+// TransactionFinder loads the transactions whose rates are audited.
+type TransactionFinder interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
+}
+
+// HistoryConfig says which pairs are sampled and how long bars are kept.
+type HistoryConfig struct {
+	pairs            []currencyPair
+	MinuteRetention  time.Duration
+	HourRetention    time.Duration
+	SnapshotInterval time.Duration
+}
+
+// HistoryConfigFromConfig builds the rate history settings from service
+// configuration, failing on a malformed pair.
+func HistoryConfigFromConfig(cfg config.ForexConfig) (HistoryConfig, error) {
+	h := HistoryConfig{
+		pairs:            trackedPairs(),
+		MinuteRetention:  cfg.MinuteBarRetention,
+		HourRetention:    cfg.HourBarRetention,
+		SnapshotInterval: cfg.SnapshotInterval,
+	}
+	if len(cfg.SnapshotPairs) > 0 {
+		h.pairs = nil
+		for _, p := range cfg.SnapshotPairs {
+			from, to, ok := strings.Cut(strings.ToUpper(p), "-")
+			if !ok || len(from) != 3 || len(to) != 3 || from == to {
+				return HistoryConfig{}, fmt.Errorf("invalid currency pair %q, want e.g. MWK-ZAR", p)
+			}
+			h.pairs = append(h.pairs, currencyPair{domain.Currency(from), domain.Currency(to)})
+		}
+	}
+	return h, nil
+}
+
+// WithRateHistory keeps sampled rates as minute, hour and day OHLC bars
+// for charts, and lets admins audit the rate a transaction was converted
+// at against them.
+func (s *Service) WithRateHistory(repo RateHistoryRepository, txs TransactionFinder, cfg HistoryConfig) *Service {
+	s.history, s.txs, s.historyCfg = repo, txs, cfg
+	return s
+}
+
+// CaptureRates samples the current mid-market rate of each configured
+// pair into the history. A pair without a rate is skipped; the error is
+// only returned when no pair was captured.
+func (s *Service) CaptureRates(ctx context.Context, at time.Time) (int, error) {
+	if s.history == nil {
+		return 0, ErrHistoryDisabled
+	}
+	captured := 0
+	var lastErr error
+	for _, pair := range s.historyCfg.pairs {
+		if ctx.Err() != nil {
+			return captured, ctx.Err()
+		}
+		rate, err := s.GetRate(ctx, pair.from, pair.to)
+		if err == nil {
+			err = s.history.RecordSample(ctx, pair.from, pair.to, rate.Rate, at)
+		}
+		if err != nil {
+			lastErr = err
+			s.logger.Warn("Failed to capture rate", map[string]interface{}{
+				"pair":  rateKey(pair.from, pair.to),
+				"error": err.Error(),
+			})
+			continue
+		}
+		captured++
+	}
+	if captured == 0 && lastErr != nil {
+		return 0, lastErr
+	}
+	return captured, nil
+}
+
+// PruneHistory drops minute and hour bars past their retention. Day bars
+// are kept.
+func (s *Service) PruneHistory(ctx context.Context, now time.Time) (int, error) {
+	if s.history == nil {
+		return 0, ErrHistoryDisabled
+	}
+	pruned := 0
+	for res, keep := range map[domain.RateResolution]time.Duration{
+		domain.RateMinute: s.historyCfg.MinuteRetention,
+		domain.RateHour:   s.historyCfg.HourRetention,
+	} {
+		if keep <= 0 {
+			continue
+		}
+		n, err := s.history.PruneBars(ctx, res, now.Add(-keep))
+		if err != nil {
+			return pruned, err
+		}
+		pruned += n
+	}
+	return pruned, nil
+}
+
+// SeriesRequest asks for a pair's bars starting in [Start, End). A zero
+// End is now, and a zero Start the default span of the resolution before
+// End.
+type SeriesRequest struct {
+	From       domain.Currency
+	To         domain.Currency
+	Resolution domain.RateResolution
+	Start      time.Time
+	End        time.Time
+}
+
+// RateSeries is a pair's OHLC bars, oldest first. Periods without samples
+// have no bar.
+type RateSeries struct {
+	From       domain.Currency       `json:"from"`
+	To         domain.Currency       `json:"to"`
+	Resolution domain.RateResolution `json:"resolution"`
+	Start      time.Time             `json:"start"`
+	End        time.Time             `json:"end"`
+	Bars       []domain.RateBar      `json:"bars"`
+}
+
+// defaultSpans is how far back a series goes when no start is given.
+var defaultSpans = map[domain.RateResolution]time.Duration{
+	domain.RateMinute: 6 * time.Hour,
+	domain.RateHour:   7 * 24 * time.Hour,
+	domain.RateDay:    365 * 24 * time.Hour,
+}
+
+// Series returns a pair's OHLC bars for charts.
+func (s *Service) Series(ctx context.Context, req SeriesRequest) (*RateSeries, error) {
+	if s.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	if req.From == "" || req.To == "" || req.From == req.To {
+		return nil, fmt.Errorf("%w: from and to must be two different currencies", ErrInvalidSeries)
+	}
+	if req.Resolution == "" {
+		req.Resolution = domain.RateHour
+	}
+	if !req.Resolution.Valid() {
+		return nil, fmt.Errorf("%w: resolution must be minute, hour or day", ErrInvalidSeries)
+	}
+	if req.End.IsZero() {
+		req.End = time.Now()
+	}
+	if req.Start.IsZero() {
+		req.Start = req.End.Add(-defaultSpans[req.Resolution])
+	}
+	req.Start, req.End = req.Resolution.BucketStart(req.Start), req.End.UTC()
+	if !req.End.After(req.Start) {
+		return nil, fmt.Errorf("%w: end must be after start", ErrInvalidSeries)
+	}
+	if req.End.Sub(req.Start)/req.Resolution.Duration() > maxSeriesBars {
+		return nil, fmt.Errorf("%w: at most %d bars per request, use a coarser resolution or a shorter period", ErrInvalidSeries, maxSeriesBars)
+	}
+	bars, err := s.history.Bars(ctx, req.From, req.To, req.Resolution, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	return &RateSeries{From: req.From, To: req.To, Resolution: req.Resolution, Start: req.Start, End: req.End, Bars: bars}, nil
+}
+
+// TransactionRate returns the rate a transaction was converted at, with
+// the market at the time: the finest bar still kept covering when it was
+// recorded.
+func (s *Service) TransactionRate(ctx context.Context, id uuid.UUID) (*domain.TransactionRate, error) {
+	if s.history == nil || s.txs == nil {
+		return nil, ErrHistoryDisabled
+	}
+	tx, err := s.txs.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tx.ConvertedCurrency == "" || tx.ConvertedCurrency == tx.Currency {
+		return nil, ErrNotConverted
+	}
+	audit := &domain.TransactionRate{
+		TransactionID:   tx.ID,
+		Reference:       tx.Reference,
+		From:            tx.Currency,
+		To:              tx.ConvertedCurrency,
+		Amount:          tx.Amount,
+		ConvertedAmount: tx.ConvertedAmount,
+		AppliedRate:     tx.ExchangeRate,
+		ConvertedAt:     tx.CreatedAt,
+	}
+	if v, ok := tx.Metadata["fx_mid_rate"].(string); ok {
+		if mid, err := decimal.NewFromString(v); err == nil {
+			audit.MidMarketRate = &mid
+		}
+	}
+	audit.QuoteID, _ = tx.Metadata["quote_id"].(string)
+
+	for _, res := range domain.RateResolutions {
+		bar, err := s.history.BarAt(ctx, tx.Currency, tx.ConvertedCurrency, res, tx.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if bar != nil {
+			audit.Market = bar
+			if bar.Close.IsPositive() {
+				markup := bar.Close.Sub(tx.ExchangeRate).Div(bar.Close).Mul(decimal.NewFromInt(100)).Round(4)
+				audit.MarkupPct = &markup
+			}
+			break
+		}
+	}
+	return audit, nil
+}
+
+// SnapshotWorker samples rates into the history and prunes old bars.
+type SnapshotWorker struct {
+	service  *Service
+	interval time.Duration
+	logger   logger.Logger
+	pulse    heartbeat.Pulse
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewSnapshotWorker(service *Service, interval time.Duration, log logger.Logger) *SnapshotWorker {
+	return &SnapshotWorker{service: service, interval: interval, logger: log, pulse: heartbeat.Nop, stop: make(chan struct{})}
+}
+
+// WithHeartbeat beats p after every pass.
+func (w *SnapshotWorker) WithHeartbeat(p heartbeat.Pulse) *SnapshotWorker {
+	w.pulse = p
+	return w
+}
+
+func (w *SnapshotWorker) Start() {
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				w.run(now)
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	w.logger.Info("Rate snapshot worker started", map[string]interface{}{"interval": w.interval.String()})
+}
+
+func (w *SnapshotWorker) run(now time.Time) {
+	ctx := context.Background()
+	if _, err := w.service.CaptureRates(ctx, now); err != nil {
+		w.logger.Error("Failed to capture rates", map[string]interface{}{"error": err.Error()})
+	}
+	if n, err := w.service.PruneHistory(ctx, now); err != nil {
+		w.logger.Error("Failed to prune rate history", map[string]interface{}{"error": err.Error()})
+	} else if n > 0 {
+		w.logger.Info("Pruned rate history", map[string]interface{}{"bars": n})
+	}
+	w.pulse.Beat()
+}
+
+func (w *SnapshotWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
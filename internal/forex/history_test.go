@@ -0,0 +1,224 @@
+package forex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memBars aggregates samples into bars as the database does, and records
+// the cutoffs it was pruned at.
+type memBars struct {
+	bars   map[domain.RateResolution][]domain.RateBar
+	pruned map[domain.RateResolution]time.Time
+}
+
+func newMemBars() *memBars {
+	return &memBars{bars: map[domain.RateResolution][]domain.RateBar{}, pruned: map[domain.RateResolution]time.Time{}}
+}
+
+func (m *memBars) RecordSample(ctx context.Context, from, to domain.Currency, rate decimal.Decimal, at time.Time) error {
+	for _, res := range domain.RateResolutions {
+		start := res.BucketStart(at)
+		bars := m.bars[res]
+		i := len(bars) - 1
+		if i < 0 || !bars[i].BucketStart.Equal(start) || bars[i].BaseCurrency != from || bars[i].TargetCurrency != to {
+			m.bars[res] = append(bars, domain.RateBar{
+				BaseCurrency: from, TargetCurrency: to, Resolution: res, BucketStart: start,
+				Open: rate, High: rate, Low: rate, Close: rate, Samples: 1, FirstAt: at, LastAt: at,
+			})
+			continue
+		}
+		b := &bars[i]
+		b.High, b.Low = decimal.Max(b.High, rate), decimal.Min(b.Low, rate)
+		b.Close, b.LastAt = rate, at
+		b.Samples++
+	}
+	return nil
+}
+
+func (m *memBars) Bars(ctx context.Context, from, to domain.Currency, res domain.RateResolution, start, end time.Time) ([]domain.RateBar, error) {
+	out := []domain.RateBar{}
+	for _, b := range m.bars[res] {
+		if b.BaseCurrency == from && b.TargetCurrency == to && !b.BucketStart.Before(start) && b.BucketStart.Before(end) {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func (m *memBars) BarAt(ctx context.Context, from, to domain.Currency, res domain.RateResolution, at time.Time) (*domain.RateBar, error) {
+	for _, b := range m.bars[res] {
+		if b.BaseCurrency == from && b.TargetCurrency == to && b.BucketStart.Equal(res.BucketStart(at)) {
+			return &b, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memBars) PruneBars(ctx context.Context, res domain.RateResolution, before time.Time) (int, error) {
+	m.pruned[res] = before
+	kept := m.bars[res][:0]
+	for _, b := range m.bars[res] {
+		if !b.BucketStart.Before(before) {
+			kept = append(kept, b)
+		}
+	}
+	n := len(m.bars[res]) - len(kept)
+	m.bars[res] = kept
+	return n, nil
+}
+
+type memTransactions map[uuid.UUID]*domain.Transaction
+
+func (m memTransactions) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	tx, ok := m[id]
+	if !ok {
+		return nil, pkgerrors.ErrTransactionNotFound
+	}
+	return tx, nil
+}
+
+func newHistoryService(pairs ...string) (*Service, *memBars, memTransactions) {
+	repo := &fakeRateRepo{latest: map[string]*domain.ExchangeRate{"MWK-ZAR": testRate(domain.MWK, domain.ZAR, 100)}}
+	cfg, err := HistoryConfigFromConfig(config.ForexConfig{
+		SnapshotPairs:      pairs,
+		MinuteBarRetention: 24 * time.Hour,
+		HourBarRetention:   30 * 24 * time.Hour,
+	})
+	if err != nil {
+		panic(err)
+	}
+	bars, txs := newMemBars(), memTransactions{}
+	return NewService(repo, nil, nil, logger.NewNop()).WithRateHistory(bars, txs, cfg), bars, txs
+}
+
+func TestHistoryConfigFromConfig(t *testing.T) {
+	cfg, err := HistoryConfigFromConfig(config.ForexConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, trackedPairs(), cfg.pairs, "every tracked pair by default")
+
+	cfg, err = HistoryConfigFromConfig(config.ForexConfig{SnapshotPairs: []string{"mwk-zar", "USD-MWK"}})
+	require.NoError(t, err)
+	assert.Equal(t, []currencyPair{{domain.MWK, domain.ZAR}, {domain.USD, domain.MWK}}, cfg.pairs)
+
+	for _, bad := range []string{"MWKZAR", "MWK-MWK", "MW-ZAR"} {
+		_, err = HistoryConfigFromConfig(config.ForexConfig{SnapshotPairs: []string{bad}})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCaptureRates(t *testing.T) {
+	ctx := context.Background()
+	s, bars, _ := newHistoryService("MWK-ZAR", "MWK-KES")
+	at := time.Date(2026, 3, 1, 9, 0, 30, 0, time.UTC)
+
+	n, err := s.CaptureRates(ctx, at)
+	require.NoError(t, err, "a pair without a rate does not fail the rest")
+	assert.Equal(t, 1, n)
+	for _, res := range domain.RateResolutions {
+		require.Len(t, bars.bars[res], 1, res)
+		b := bars.bars[res][0]
+		assert.Equal(t, res.BucketStart(at), b.BucketStart)
+		assert.Equal(t, "100", b.Close.String())
+	}
+
+	none, _, _ := newHistoryService("MWK-KES")
+	_, err = none.CaptureRates(ctx, at)
+	assert.Error(t, err, "nothing captured")
+}
+
+func TestSeries(t *testing.T) {
+	ctx := context.Background()
+	s, bars, _ := newHistoryService()
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, r := range []int64{100, 104, 98, 101} {
+		require.NoError(t, bars.RecordSample(ctx, domain.MWK, domain.ZAR, decimal.NewFromInt(r), start.Add(time.Duration(i)*20*time.Minute)))
+	}
+
+	series, err := s.Series(ctx, SeriesRequest{From: domain.MWK, To: domain.ZAR, Resolution: domain.RateHour, Start: start.Add(5 * time.Minute), End: start.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, start, series.Start, "the start is aligned to the bar it falls in")
+	require.Len(t, series.Bars, 2)
+	b := series.Bars[0]
+	assert.Equal(t, []string{"100", "104", "98", "98"}, []string{b.Open.String(), b.High.String(), b.Low.String(), b.Close.String()})
+	assert.Equal(t, 3, b.Samples)
+	assert.Equal(t, "101", series.Bars[1].Open.String(), "the last sample opens the next hour")
+
+	series, err = s.Series(ctx, SeriesRequest{From: domain.MWK, To: domain.ZAR, End: start.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, domain.RateHour, series.Resolution, "hourly by default")
+	assert.Equal(t, 7*24*time.Hour, series.End.Sub(series.Start))
+
+	for name, req := range map[string]SeriesRequest{
+		"same currency":  {From: domain.MWK, To: domain.MWK},
+		"resolution":     {From: domain.MWK, To: domain.ZAR, Resolution: "week"},
+		"backwards":      {From: domain.MWK, To: domain.ZAR, Start: start, End: start.Add(-time.Hour)},
+		"too many bars":  {From: domain.MWK, To: domain.ZAR, Resolution: domain.RateMinute, Start: start, End: start.Add(48 * time.Hour)},
+		"missing target": {From: domain.MWK},
+	} {
+		_, err := s.Series(ctx, req)
+		assert.ErrorIs(t, err, ErrInvalidSeries, name)
+	}
+}
+
+func TestTransactionRate(t *testing.T) {
+	ctx := context.Background()
+	s, bars, txs := newHistoryService()
+	at := time.Date(2026, 3, 1, 9, 10, 0, 0, time.UTC)
+	require.NoError(t, bars.RecordSample(ctx, domain.MWK, domain.ZAR, decimal.NewFromInt(100), at.Add(-5*time.Minute)))
+	// Minute bars from then are gone; the hour bar is the finest left.
+	_, err := bars.PruneBars(ctx, domain.RateMinute, at.Add(time.Hour))
+	require.NoError(t, err)
+
+	tx := &domain.Transaction{
+		ID:                uuid.New(),
+		Reference:         "KYD-1",
+		Amount:            decimal.NewFromInt(1000),
+		Currency:          domain.MWK,
+		ExchangeRate:      decimal.NewFromInt(98),
+		ConvertedAmount:   decimal.NewFromInt(98000),
+		ConvertedCurrency: domain.ZAR,
+		Metadata:          domain.Metadata{"fx_mid_rate": "100", "quote_id": "q-1"},
+		CreatedAt:         at,
+	}
+	txs[tx.ID] = tx
+
+	audit, err := s.TransactionRate(ctx, tx.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "98", audit.AppliedRate.String())
+	assert.Equal(t, "100", audit.MidMarketRate.String())
+	assert.Equal(t, "q-1", audit.QuoteID)
+	require.NotNil(t, audit.Market)
+	assert.Equal(t, domain.RateHour, audit.Market.Resolution)
+	assert.Equal(t, "2", audit.MarkupPct.String())
+
+	local := &domain.Transaction{ID: uuid.New(), Currency: domain.MWK, ConvertedCurrency: domain.MWK, CreatedAt: at}
+	txs[local.ID] = local
+	_, err = s.TransactionRate(ctx, local.ID)
+	assert.ErrorIs(t, err, ErrNotConverted)
+
+	_, err = s.TransactionRate(ctx, uuid.New())
+	assert.ErrorIs(t, err, pkgerrors.ErrTransactionNotFound)
+}
+
+func TestPruneHistory(t *testing.T) {
+	s, bars, _ := newHistoryService()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	_, err := s.PruneHistory(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-24*time.Hour), bars.pruned[domain.RateMinute])
+	assert.Equal(t, now.Add(-30*24*time.Hour), bars.pruned[domain.RateHour])
+	assert.NotContains(t, bars.pruned, domain.RateDay, "day bars are kept")
+}
@@ -0,0 +1,98 @@
+package forex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+)
+
+const (
+	// DefaultLocalRateMaxAge bounds how long a rate is served from process
+	// memory without being refreshed by the rate bus or a lookup.
+	DefaultLocalRateMaxAge = 2 * time.Minute
+
+	// redisRateTTL outlives one updater cycle so the shared cache never
+	// empties between refreshes.
+	redisRateTTL = 10 * time.Minute
+)
+
+func rateKey(from, to domain.Currency) string {
+	return fmt.Sprintf("%s-%s", from, to)
+}
+
+// rateValid reports whether rate has not passed its ValidTo.
+func rateValid(rate *domain.ExchangeRate) bool {
+	return rate.ValidTo == nil || rate.ValidTo.After(time.Now())
+}
+
+type localRate struct {
+	rate     *domain.ExchangeRate
+	storedAt time.Time
+}
+
+// localRates is the in-process rate cache. An entry is served until it is
+// maxAge old or past its ValidTo, whichever comes first.
+type localRates struct {
+	mu     sync.RWMutex
+	rates  map[string]localRate
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+func newLocalRates(maxAge time.Duration) *localRates {
+	return &localRates{rates: make(map[string]localRate), maxAge: maxAge, now: time.Now}
+}
+
+func (c *localRates) get(key string) (*domain.ExchangeRate, bool) {
+	c.mu.RLock()
+	entry, ok := c.rates[key]
+	c.mu.RUnlock()
+	if !ok || c.now().Sub(entry.storedAt) > c.maxAge || !rateValid(entry.rate) {
+		return nil, false
+	}
+	return entry.rate, true
+}
+
+func (c *localRates) put(key string, rate *domain.ExchangeRate) {
+	c.mu.Lock()
+	c.rates[key] = localRate{rate: rate, storedAt: c.now()}
+	c.mu.Unlock()
+}
+
+// WithLocalMaxAge sets how long rates are served from process memory.
+// Zero or less keeps the default.
+func (s *Service) WithLocalMaxAge(maxAge time.Duration) *Service {
+	if maxAge > 0 {
+		s.local.maxAge = maxAge
+	}
+	return s
+}
+
+// WarmRates loads the tracked pairs from Redis, or else the database, into
+// the in-process cache so the first payments after startup do not pay for
+// the lookups. Pairs with no valid stored rate are skipped; they are fetched
+// on first use.
+func (s *Service) WarmRates(ctx context.Context) int {
+	warmed := 0
+	for _, pair := range trackedPairs() {
+		if ctx.Err() != nil {
+			break
+		}
+		key := rateKey(pair.from, pair.to)
+		if s.cache != nil {
+			if rate, err := s.cache.Get(key); err == nil && rateValid(rate) {
+				s.local.put(key, rate)
+				warmed++
+				continue
+			}
+		}
+		if rate, err := s.repo.GetLatestRate(ctx, pair.from, pair.to); err == nil && rateValid(rate) {
+			s.local.put(key, rate)
+			warmed++
+		}
+	}
+	return warmed
+}
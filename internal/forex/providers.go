@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"kyd/internal/domain"
+	"kyd/pkg/clock"
+	"kyd/pkg/tracing"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -89,7 +91,8 @@ type erAPIResponse struct {
 func NewExchangeRateAPIProvider() *ExchangeRateAPIProvider {
 	return &ExchangeRateAPIProvider{
 		client: &http.Client{
-			Timeout: 2 * time.Second,
+			Timeout:   2 * time.Second,
+			Transport: tracing.Transport(nil),
 		},
 		cache:    make(map[string]cachedRates),
 		cacheTTL: 5 * time.Minute,
@@ -275,8 +278,8 @@ func (se *SpreadEngine) CalculateSpread(from, to string, baseRate decimal.Decima
 		factors["liquidity"] = 1.0
 	}
 
-	// Factor 3: Time of day adjustment
-	now := time.Now()
+	// Factor 3: Time of day adjustment, in business hours of the business zone
+	now := clock.BusinessNow()
 	hour := now.Hour()
 	timeAdjustment := 1.0
 
@@ -288,7 +291,7 @@ func (se *SpreadEngine) CalculateSpread(from, to string, baseRate decimal.Decima
 
 	// Factor 4: Weekend adjustment
 	weekendAdjustment := 1.0
-	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+	if clock.IsWeekend(now) {
 		weekendAdjustment = se.config.WeekendMultiplier
 		spread *= weekendAdjustment
 	}
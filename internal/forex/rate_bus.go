@@ -0,0 +1,136 @@
+package forex
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateChannel is the Redis pub/sub channel newly stored rates are announced on.
+const RateChannel = "forex:rates"
+
+// RateBus announces newly stored rates to every instance, so each one can
+// refresh its in-process cache without asking Redis on every payment.
+// Delivery is best effort: the local max age bounds how stale a cache can
+// get when a message is missed.
+type RateBus interface {
+	Publish(ctx context.Context, rate *domain.ExchangeRate) error
+	// Subscribe calls fn for each announced rate until ctx is done or the
+	// subscription fails.
+	Subscribe(ctx context.Context, fn func(*domain.ExchangeRate)) error
+}
+
+// RedisRateBus is a RateBus over Redis pub/sub.
+type RedisRateBus struct {
+	client *redis.Client
+}
+
+func NewRedisRateBus(client *redis.Client) *RedisRateBus {
+	return &RedisRateBus{client: client}
+}
+
+func (b *RedisRateBus) Publish(ctx context.Context, rate *domain.ExchangeRate) error {
+	data, err := json.Marshal(rate)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, RateChannel, data).Err()
+}
+
+func (b *RedisRateBus) Subscribe(ctx context.Context, fn func(*domain.ExchangeRate)) error {
+	sub := b.client.Subscribe(ctx, RateChannel)
+	defer sub.Close()
+	// Wait for the subscription to be confirmed so failures are reported.
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var rate domain.ExchangeRate
+			if err := json.Unmarshal([]byte(msg.Payload), &rate); err != nil {
+				continue
+			}
+			fn(&rate)
+		}
+	}
+}
+
+// WithRateBus makes the service announce the rates it stores on bus. Use a
+// RateSubscriber to receive them.
+func (s *Service) WithRateBus(bus RateBus) *Service {
+	s.bus = bus
+	return s
+}
+
+// acceptRate puts an announced rate into the in-process cache.
+func (s *Service) acceptRate(rate *domain.ExchangeRate) {
+	if rate.BaseCurrency == "" || rate.TargetCurrency == "" || !rate.Rate.IsPositive() || !rateValid(rate) {
+		return
+	}
+	s.local.put(rateKey(rate.BaseCurrency, rate.TargetCurrency), rate)
+}
+
+// rateSubscribeRetry is how long the subscriber waits before resubscribing
+// after the bus fails.
+const rateSubscribeRetry = 5 * time.Second
+
+// RateSubscriber keeps a Service's in-process cache current: it warms the
+// cache on start and then applies every rate announced on the bus.
+type RateSubscriber struct {
+	service  *Service
+	bus      RateBus
+	logger   logger.Logger
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewRateSubscriber(service *Service, bus RateBus, log logger.Logger) *RateSubscriber {
+	return &RateSubscriber{service: service, bus: bus, logger: log, done: make(chan struct{})}
+}
+
+// Start warms the cache and subscribes until Stop is called.
+func (r *RateSubscriber) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go func() {
+		defer close(r.done)
+		warmed := r.service.WarmRates(ctx)
+		r.logger.Info("Forex rate cache warmed", map[string]interface{}{"pairs": warmed})
+		for ctx.Err() == nil {
+			err := r.bus.Subscribe(ctx, r.service.acceptRate)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				r.logger.Warn("Forex rate subscription failed", map[string]interface{}{"error": err.Error()})
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(rateSubscribeRetry):
+			}
+		}
+	}()
+}
+
+func (r *RateSubscriber) Stop() {
+	r.stopOnce.Do(func() {
+		if r.cancel != nil {
+			r.cancel()
+			<-r.done
+		}
+	})
+}
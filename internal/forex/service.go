@@ -7,16 +7,15 @@ package forex
 
 import (
 	"context"
-	"fmt"
-	"sync"
 	"time"
 
 	"kyd/internal/domain"
-	"kyd/pkg/errors"
 	"kyd/pkg/logger"
+	"kyd/pkg/tracing"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Service provides forex rate retrieval, caching, and conversion.
@@ -25,9 +24,14 @@ type Service struct {
 	cache        RateCache
 	providers    []RateProvider
 	logger       logger.Logger
-	mu           sync.RWMutex
-	rateCache    map[string]*domain.ExchangeRate
+	local        *localRates
+	bus          RateBus
 	spreadEngine *SpreadEngine
+	failover     *failover
+	history      RateHistoryRepository
+	historyCfg   HistoryConfig
+	txs          TransactionFinder
+	spreads      SpreadSource
 }
 
 // NewService constructs a forex Service with repository, cache, providers, and logger.
@@ -37,8 +41,9 @@ func NewService(repo Repository, cache RateCache, providers []RateProvider, log
 		cache:        cache,
 		providers:    providers,
 		logger:       log,
-		rateCache:    make(map[string]*domain.ExchangeRate),
+		local:        newLocalRates(DefaultLocalRateMaxAge),
 		spreadEngine: NewSpreadEngine(),
+		failover:     newFailover(),
 	}
 
 	// Adjust liquidity levels for MWK to improve conversions
@@ -52,6 +57,16 @@ func NewService(repo Repository, cache RateCache, providers []RateProvider, log
 
 // GetRate retrieves the current exchange rate
 func (s *Service) GetRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
+	ctx, span := tracing.Start(ctx, "forex.GetRate", attribute.String("forex.pair", rateKey(from, to)))
+	rate, err := s.getRate(ctx, from, to)
+	if err == nil && from != to {
+		rate = s.applySpread(ctx, rate)
+	}
+	tracing.End(span, err)
+	return rate, err
+}
+
+func (s *Service) getRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
 	if from == to {
 		return &domain.ExchangeRate{
 			BaseCurrency:   from,
@@ -63,34 +78,27 @@ func (s *Service) GetRate(ctx context.Context, from, to domain.Currency) (*domai
 		}, nil
 	}
 
-	// Try cache first (In-Memory)
-	key := fmt.Sprintf("%s-%s", from, to)
-	s.mu.RLock()
-	if rate, ok := s.rateCache[key]; ok {
-		if rate.ValidTo == nil || rate.ValidTo.After(time.Now()) {
-			s.mu.RUnlock()
-			return rate, nil
-		}
+	// Try cache first (In-Memory). Entries older than the local max age
+	// are ignored, so a missed invalidation cannot serve a stale rate for
+	// long; the lookups below are the authoritative path.
+	key := rateKey(from, to)
+	if rate, ok := s.local.get(key); ok {
+		return rate, nil
 	}
-	s.mu.RUnlock()
 
 	// Try Distributed Cache (Redis)
 	if s.cache != nil {
-		if rate, err := s.cache.Get(key); err == nil {
-			if rate.ValidTo == nil || rate.ValidTo.After(time.Now()) {
-				// Populate in-memory cache for next time
-				s.mu.Lock()
-				s.rateCache[key] = rate
-				s.mu.Unlock()
-				return rate, nil
-			}
+		if rate, err := s.cache.Get(key); err == nil && rateValid(rate) {
+			// Populate in-memory cache for next time
+			s.local.put(key, rate)
+			return rate, nil
 		}
 	}
 
 	// Try database
 	rate, err := s.repo.GetLatestRate(ctx, from, to)
-	if err == nil && (rate.ValidTo == nil || rate.ValidTo.After(time.Now())) {
-		s.updateCache(key, rate)
+	if err == nil && rateValid(rate) {
+		s.local.put(key, rate)
 		return rate, nil
 	}
 
@@ -99,39 +107,27 @@ func (s *Service) GetRate(ctx context.Context, from, to domain.Currency) (*domai
 }
 
 func (s *Service) fetchAndStoreRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
-	for _, provider := range s.providers {
-		rate, err := provider.GetRate(ctx, from, to)
-		if err != nil {
-			s.logger.Warn("Provider failed", map[string]interface{}{
-				"provider": provider.Name(),
-				"from":     from,
-				"to":       to,
-				"error":    err.Error(),
-			})
-			continue
-		}
-
-		// Apply dynamic spread using spread engine
-		spreadRes := s.spreadEngine.CalculateSpread(string(from), string(to), rate.Rate)
-		rate.BuyRate = spreadRes.BuyRate
-		rate.SellRate = spreadRes.SellRate
-		rate.Spread = spreadRes.Spread
-		rate.ID = uuid.New()
-		rate.CreatedAt = time.Now()
-
-		// Store in database
-		if err := s.repo.CreateRate(ctx, rate); err != nil {
-			s.logger.Error("Failed to store rate", map[string]interface{}{"error": err.Error()})
-		}
-
-		// Update cache
-		key := fmt.Sprintf("%s-%s", from, to)
-		s.updateCache(key, rate)
+	rate, err := s.fetchRate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
 
-		return rate, nil
+	// Apply dynamic spread using spread engine
+	spreadRes := s.spreadEngine.CalculateSpread(string(from), string(to), rate.Rate)
+	rate.BuyRate = spreadRes.BuyRate
+	rate.SellRate = spreadRes.SellRate
+	rate.Spread = spreadRes.Spread
+	rate.ID = uuid.New()
+	rate.CreatedAt = time.Now()
+
+	// Store in database
+	if err := s.repo.CreateRate(ctx, rate); err != nil {
+		s.logger.Error("Failed to store rate", map[string]interface{}{"error": err.Error()})
 	}
 
-	return nil, errors.ErrRateNotAvailable
+	s.storeCached(ctx, rateKey(from, to), rate)
+
+	return rate, nil
 }
 
 // GetHistory retrieves historical exchange rates for a currency pair.
@@ -139,10 +135,20 @@ func (s *Service) GetHistory(ctx context.Context, from, to domain.Currency, limi
 	return s.repo.GetRateHistory(ctx, from, to, limit)
 }
 
-func (s *Service) updateCache(key string, rate *domain.ExchangeRate) {
-	s.mu.Lock()
-	s.rateCache[key] = rate
-	s.mu.Unlock()
+// storeCached caches a newly stored rate locally and in Redis and tells
+// the other instances about it.
+func (s *Service) storeCached(ctx context.Context, key string, rate *domain.ExchangeRate) {
+	s.local.put(key, rate)
+	if s.cache != nil {
+		if err := s.cache.Set(key, rate, redisRateTTL); err != nil {
+			s.logger.Warn("Failed to cache rate", map[string]interface{}{"key": key, "error": err.Error()})
+		}
+	}
+	if s.bus != nil {
+		if err := s.bus.Publish(ctx, rate); err != nil {
+			s.logger.Warn("Failed to publish rate", map[string]interface{}{"key": key, "error": err.Error()})
+		}
+	}
 }
 
 func (s *Service) startRateUpdater() {
@@ -157,6 +163,23 @@ func (s *Service) startRateUpdater() {
 func (s *Service) updateAllRates() {
 	ctx := context.Background()
 
+	for _, pair := range trackedPairs() {
+		_, err := s.fetchAndStoreRate(ctx, pair.from, pair.to)
+		if err != nil {
+			s.logger.Error("Failed to update rate", map[string]interface{}{
+				"from":  pair.from,
+				"to":    pair.to,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+type currencyPair struct{ from, to domain.Currency }
+
+// trackedPairs are the pairs refreshed by the rate updater: every tracked
+// currency against MWK, both ways.
+func trackedPairs() []currencyPair {
 	currencies := []domain.Currency{
 		domain.CNY, domain.ZMW,
 		domain.ZAR, domain.KES, domain.NGN, domain.GHS, domain.UGX, domain.TZS, domain.RWF,
@@ -164,24 +187,14 @@ func (s *Service) updateAllRates() {
 		domain.EUR, domain.GBP, domain.CHF,
 	}
 
-	var pairs []struct{ from, to domain.Currency }
+	var pairs []currencyPair
 
 	// Add pairs relative to MWK
 	for _, c := range currencies {
-		pairs = append(pairs, struct{ from, to domain.Currency }{domain.MWK, c})
-		pairs = append(pairs, struct{ from, to domain.Currency }{c, domain.MWK})
-	}
-
-	for _, pair := range pairs {
-		_, err := s.fetchAndStoreRate(ctx, pair.from, pair.to)
-		if err != nil {
-			s.logger.Error("Failed to update rate", map[string]interface{}{
-				"from":  pair.from,
-				"to":    pair.to,
-				"error": err.Error(),
-			})
-		}
+		pairs = append(pairs, currencyPair{domain.MWK, c})
+		pairs = append(pairs, currencyPair{c, domain.MWK})
 	}
+	return pairs
 }
 
 // CalculateRequest represents a conversion request payload.
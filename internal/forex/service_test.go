@@ -0,0 +1,219 @@
+package forex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRateRepo struct {
+	mu      sync.Mutex
+	latest  map[string]*domain.ExchangeRate
+	created []*domain.ExchangeRate
+	lookups int
+}
+
+func (r *fakeRateRepo) CreateRate(ctx context.Context, rate *domain.ExchangeRate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.created = append(r.created, rate)
+	return nil
+}
+
+func (r *fakeRateRepo) GetLatestRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	if rate, ok := r.latest[rateKey(from, to)]; ok {
+		return rate, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *fakeRateRepo) GetRateHistory(ctx context.Context, from, to domain.Currency, limit int) ([]*domain.ExchangeRate, error) {
+	return nil, nil
+}
+
+type fakeRateCache struct {
+	mu    sync.Mutex
+	rates map[string]*domain.ExchangeRate
+	gets  int
+}
+
+func (c *fakeRateCache) Get(key string) (*domain.ExchangeRate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	if rate, ok := c.rates[key]; ok {
+		return rate, nil
+	}
+	return nil, errors.New("miss")
+}
+
+func (c *fakeRateCache) Set(key string, rate *domain.ExchangeRate, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rates[key] = rate
+	return nil
+}
+
+type fakeRateBus struct {
+	published []*domain.ExchangeRate
+}
+
+func (b *fakeRateBus) Publish(ctx context.Context, rate *domain.ExchangeRate) error {
+	b.published = append(b.published, rate)
+	return nil
+}
+
+func (b *fakeRateBus) Subscribe(ctx context.Context, fn func(*domain.ExchangeRate)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type fixedProvider struct{ rate decimal.Decimal }
+
+func (p fixedProvider) Name() string { return "fixed" }
+
+func (p fixedProvider) GetRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error) {
+	return &domain.ExchangeRate{BaseCurrency: from, TargetCurrency: to, Rate: p.rate}, nil
+}
+
+func testRate(from, to domain.Currency, rate int64) *domain.ExchangeRate {
+	return &domain.ExchangeRate{BaseCurrency: from, TargetCurrency: to, Rate: decimal.NewFromInt(rate), SellRate: decimal.NewFromInt(rate)}
+}
+
+func TestGetRateServesFreshLocalRatesWithoutRedis(t *testing.T) {
+	repo := &fakeRateRepo{latest: map[string]*domain.ExchangeRate{}}
+	cache := &fakeRateCache{rates: map[string]*domain.ExchangeRate{"MWK-CNY": testRate(domain.MWK, domain.CNY, 2)}}
+	s := NewService(repo, cache, nil, logger.NewNop())
+	ctx := context.Background()
+
+	now := time.Now()
+	s.local.now = func() time.Time { return now }
+
+	rate, err := s.GetRate(ctx, domain.MWK, domain.CNY)
+	require.NoError(t, err)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(2)))
+	assert.Equal(t, 1, cache.gets)
+
+	// Within the max age the rate comes from memory.
+	now = now.Add(DefaultLocalRateMaxAge - time.Second)
+	_, err = s.GetRate(ctx, domain.MWK, domain.CNY)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cache.gets)
+
+	// Past it, the authoritative path is consulted again.
+	now = now.Add(2 * time.Second)
+	cache.rates["MWK-CNY"] = testRate(domain.MWK, domain.CNY, 3)
+	rate, err = s.GetRate(ctx, domain.MWK, domain.CNY)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.gets)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(3)))
+}
+
+func TestFetchedRatesAreCachedAndPublished(t *testing.T) {
+	repo := &fakeRateRepo{latest: map[string]*domain.ExchangeRate{}}
+	cache := &fakeRateCache{rates: map[string]*domain.ExchangeRate{}}
+	bus := &fakeRateBus{}
+	s := NewService(repo, cache, []RateProvider{fixedProvider{decimal.NewFromInt(5)}}, logger.NewNop()).WithRateBus(bus)
+
+	rate, err := s.GetRate(context.Background(), domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Len(t, repo.created, 1)
+	assert.Equal(t, rate, cache.rates["MWK-ZAR"])
+	require.Len(t, bus.published, 1)
+	assert.Equal(t, rate, bus.published[0])
+}
+
+func TestAnnouncedRatesReplaceLocalRates(t *testing.T) {
+	repo := &fakeRateRepo{latest: map[string]*domain.ExchangeRate{"MWK-KES": testRate(domain.MWK, domain.KES, 7)}}
+	s := NewService(repo, nil, nil, logger.NewNop())
+	ctx := context.Background()
+
+	_, err := s.GetRate(ctx, domain.MWK, domain.KES)
+	require.NoError(t, err)
+
+	s.acceptRate(testRate(domain.MWK, domain.KES, 8))
+	s.acceptRate(&domain.ExchangeRate{BaseCurrency: domain.MWK, TargetCurrency: domain.KES}) // no rate: ignored
+
+	rate, err := s.GetRate(ctx, domain.MWK, domain.KES)
+	require.NoError(t, err)
+	assert.True(t, rate.Rate.Equal(decimal.NewFromInt(8)))
+	assert.Equal(t, 1, repo.lookups)
+}
+
+func TestWarmRatesLoadsTrackedPairs(t *testing.T) {
+	repo := &fakeRateRepo{latest: map[string]*domain.ExchangeRate{"CNY-MWK": testRate(domain.CNY, domain.MWK, 250)}}
+	cache := &fakeRateCache{rates: map[string]*domain.ExchangeRate{"MWK-CNY": testRate(domain.MWK, domain.CNY, 2)}}
+	s := NewService(repo, cache, nil, logger.NewNop())
+
+	assert.Equal(t, 2, s.WarmRates(context.Background()))
+	_, ok := s.local.get("CNY-MWK")
+	assert.True(t, ok)
+	_, ok = s.local.get("MWK-CNY")
+	assert.True(t, ok)
+
+	sub := NewRateSubscriber(s, &fakeRateBus{}, logger.NewNop())
+	sub.Start()
+	sub.Stop()
+}
+
+type fakeSpreads map[string]*domain.FXSpread
+
+func (f fakeSpreads) SpreadFor(ctx context.Context, from, to domain.Currency) (*domain.FXSpread, error) {
+	if sp, ok := f[rateKey(from, to)]; ok {
+		return sp, nil
+	}
+	if from == domain.GBP {
+		return nil, errors.New("unavailable")
+	}
+	return nil, nil
+}
+
+func TestSpreadsPriceOffMidRate(t *testing.T) {
+	pip := decimal.RequireFromString("0.01")
+	repo := &fakeRateRepo{latest: map[string]*domain.ExchangeRate{}}
+	cache := &fakeRateCache{rates: map[string]*domain.ExchangeRate{
+		"MWK-ZAR": testRate(domain.MWK, domain.ZAR, 2),
+		"ZAR-MWK": testRate(domain.ZAR, domain.MWK, 100),
+		"MWK-KES": testRate(domain.MWK, domain.KES, 1),
+		"GBP-MWK": testRate(domain.GBP, domain.MWK, 2000),
+	}}
+	s := NewService(repo, cache, nil, logger.NewNop()).WithSpreads(fakeSpreads{
+		"MWK-ZAR": {Unit: domain.FXSpreadPercent, Value: decimal.RequireFromString("1.5")},
+		"ZAR-MWK": {Unit: domain.FXSpreadPips, Value: decimal.NewFromInt(50), PipSize: &pip},
+		"MWK-KES": {Unit: domain.FXSpreadPips, Value: decimal.NewFromInt(20000)},
+	})
+	ctx := context.Background()
+
+	rate, err := s.GetRate(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Equal(t, "2", rate.Rate.String(), "the mid rate is kept")
+	assert.Equal(t, "1.97", rate.SellRate.String())
+	assert.Equal(t, "2.03", rate.BuyRate.String())
+	assert.Equal(t, "0.03", rate.Spread.String())
+	assert.Equal(t, "2", cache.rates["MWK-ZAR"].SellRate.String(), "the cached rate is not changed")
+
+	rate, err = s.GetRate(ctx, domain.ZAR, domain.MWK)
+	require.NoError(t, err)
+	assert.Equal(t, "99.5", rate.SellRate.String())
+	assert.Equal(t, "100.5", rate.BuyRate.String())
+
+	rate, err = s.GetRate(ctx, domain.MWK, domain.KES)
+	require.NoError(t, err)
+	assert.Equal(t, "1", rate.SellRate.String(), "a margin wiping out the rate is ignored")
+
+	rate, err = s.GetRate(ctx, domain.GBP, domain.MWK)
+	require.NoError(t, err)
+	assert.Equal(t, "2000", rate.SellRate.String(), "spreads that cannot be loaded are skipped")
+}
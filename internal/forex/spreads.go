@@ -0,0 +1,60 @@
+package forex
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+// SpreadSource returns the FX spread an admin set on a pair, or nil when
+// the pair has none.
+type SpreadSource interface {
+	SpreadFor(ctx context.Context, from, to domain.Currency) (*domain.FXSpread, error)
+}
+
+// WithSpreads prices pairs that have a spread set at the mid rate less and
+// plus its margin rather than at the dynamic spread. The spread is applied
+// on every read, so a change takes effect without waiting for cached
+// rates to expire.
+func (s *Service) WithSpreads(src SpreadSource) *Service {
+	s.spreads = src
+	return s
+}
+
+// applySpread returns rate priced at the pair's spread, or rate itself when
+// it has none or the spread would leave no positive rate. Cached rates are
+// shared, so rate is copied rather than changed.
+func (s *Service) applySpread(ctx context.Context, rate *domain.ExchangeRate) *domain.ExchangeRate {
+	if s.spreads == nil || !rate.Rate.IsPositive() {
+		return rate
+	}
+	sp, err := s.spreads.SpreadFor(ctx, rate.BaseCurrency, rate.TargetCurrency)
+	if err != nil {
+		// Keep converting at the dynamic spread rather than fail payments.
+		s.logger.Warn("Failed to load fx spread, using dynamic spread", map[string]interface{}{
+			"pair":  rateKey(rate.BaseCurrency, rate.TargetCurrency),
+			"error": err.Error(),
+		})
+		return rate
+	}
+	if sp == nil {
+		return rate
+	}
+	margin := sp.Margin(rate.Rate)
+	sell := rate.Rate.Sub(margin)
+	if !sell.IsPositive() {
+		s.logger.Warn("FX spread leaves no positive rate, using dynamic spread", map[string]interface{}{
+			"pair":   rateKey(rate.BaseCurrency, rate.TargetCurrency),
+			"rate":   rate.Rate.String(),
+			"margin": margin.String(),
+		})
+		return rate
+	}
+	priced := *rate
+	priced.SellRate = sell
+	priced.BuyRate = rate.Rate.Add(margin)
+	priced.Spread = margin.Mul(decimal.NewFromInt(2)).Div(rate.Rate)
+	return &priced
+}
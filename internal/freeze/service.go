@@ -0,0 +1,352 @@
+// Package freeze is the emergency kill switch for money movement. Admins
+// can pause every movement, all cross-border payments, a single corridor
+// or all withdrawals. Each freeze, and each lift, is proposed by one admin
+// and only takes effect once a different admin approves it; every step is
+// kept as an audit trail.
+//
+// Payment initiation, withdrawals and settlement submission check the
+// active freezes, which each process caches briefly. Active freezes are
+// published on the public status page.
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound      = errors.New("freeze not found")
+	ErrInvalidFreeze = errors.New("invalid freeze")
+	ErrDuplicate     = errors.New("an identical freeze is already active or awaiting approval")
+	ErrNothingToDo   = errors.New("freeze has nothing awaiting approval")
+	ErrNotActive     = errors.New("freeze is not active")
+	ErrSelfApproval  = errors.New("a different admin must approve")
+	ErrConflict      = errors.New("freeze was changed by another admin")
+)
+
+var corridorPattern = regexp.MustCompile(`^[A-Z]{3}-[A-Z]{3}$`)
+
+type Repository interface {
+	// Create saves a proposed freeze and its first event.
+	Create(ctx context.Context, f *domain.MovementFreeze, e *domain.FreezeEvent) error
+	// Get returns nil when there is no such freeze.
+	Get(ctx context.Context, id uuid.UUID) (*domain.MovementFreeze, error)
+	// Update saves f and records e unless f changed since prev, reporting
+	// false when another admin acted first.
+	Update(ctx context.Context, f *domain.MovementFreeze, prev time.Time, e *domain.FreezeEvent) (bool, error)
+	// Open returns the freezes awaiting approval or active.
+	Open(ctx context.Context) ([]domain.MovementFreeze, error)
+	List(ctx context.Context, status domain.FreezeStatus, limit, offset int) ([]domain.MovementFreeze, int, error)
+	Events(ctx context.Context, id uuid.UUID) ([]domain.FreezeEvent, error)
+}
+
+// Request proposes a freeze.
+type Request struct {
+	Scope    domain.FreezeScope `json:"scope"`
+	Corridor string             `json:"corridor,omitempty"`
+	Reason   string             `json:"reason"`
+	// Message is shown to customers; a default for the scope when empty.
+	Message string `json:"message,omitempty"`
+}
+
+// Incident is an active freeze as the status page shows it.
+type Incident struct {
+	Scope    domain.FreezeScope `json:"scope"`
+	Corridor string             `json:"corridor,omitempty"`
+	Message  string             `json:"message"`
+	Since    time.Time          `json:"since"`
+}
+
+// StatusPage summarises the freezes in force for customers.
+type StatusPage struct {
+	// Status is "operational", or "degraded" while any freeze is active.
+	Status    string     `json:"status"`
+	Incidents []Incident `json:"incidents"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type Service struct {
+	repo   Repository
+	cfg    config.FreezeConfig
+	logger logger.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	active    []domain.MovementFreeze
+	fetchedAt time.Time
+}
+
+func NewService(repo Repository, cfg config.FreezeConfig, log logger.Logger) *Service {
+	return &Service{repo: repo, cfg: cfg, logger: log, now: time.Now}
+}
+
+// Request proposes a freeze, which takes effect once another admin
+// approves it.
+func (s *Service) Request(ctx context.Context, req Request, adminID uuid.UUID) (*domain.MovementFreeze, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Message = strings.TrimSpace(req.Message)
+	req.Corridor = strings.ToUpper(strings.TrimSpace(req.Corridor))
+	switch req.Scope {
+	case domain.FreezeAll, domain.FreezeCrossBorder, domain.FreezeWithdrawals:
+		if req.Corridor != "" {
+			return nil, fmt.Errorf("%w: corridor only applies to corridor freezes", ErrInvalidFreeze)
+		}
+	case domain.FreezeCorridor:
+		if !corridorPattern.MatchString(req.Corridor) || req.Corridor[:3] == req.Corridor[4:] {
+			return nil, fmt.Errorf("%w: corridor must be two different currencies, such as MWK-ZAR", ErrInvalidFreeze)
+		}
+	default:
+		return nil, fmt.Errorf("%w: scope must be all, cross_border, corridor or withdrawals", ErrInvalidFreeze)
+	}
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidFreeze)
+	}
+	if req.Message == "" {
+		req.Message = defaultMessage(req.Scope, req.Corridor)
+	}
+
+	open, err := s.repo.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range open {
+		if f.Scope == req.Scope && f.Corridor == req.Corridor {
+			return nil, ErrDuplicate
+		}
+	}
+
+	now := s.now()
+	f := &domain.MovementFreeze{
+		ID:          uuid.New(),
+		Scope:       req.Scope,
+		Corridor:    req.Corridor,
+		Status:      domain.FreezePending,
+		Reason:      req.Reason,
+		Message:     req.Message,
+		RequestedBy: adminID,
+		RequestedAt: now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.Create(ctx, f, s.event(f, domain.FreezeActionRequested, adminID, req.Reason)); err != nil {
+		return nil, err
+	}
+	s.logger.Warn("Money movement freeze requested", map[string]interface{}{
+		"freeze_id": f.ID, "scope": f.Scope, "corridor": f.Corridor, "admin_id": adminID,
+	})
+	return f, nil
+}
+
+// RequestLift proposes lifting an active freeze, which happens once
+// another admin approves it.
+func (s *Service) RequestLift(ctx context.Context, id, adminID uuid.UUID, note string) (*domain.MovementFreeze, error) {
+	f, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if f.Status != domain.FreezeActive {
+		return nil, ErrNotActive
+	}
+	if f.LiftRequestedBy != nil {
+		return f, nil
+	}
+	prev, now := f.UpdatedAt, s.now()
+	f.LiftRequestedBy, f.LiftRequestedAt, f.UpdatedAt = &adminID, &now, now
+	if err := s.update(ctx, f, prev, domain.FreezeActionLiftRequested, adminID, note); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Approve carries out what is awaiting approval: the freeze itself, or its
+// lift. The approver must not be the admin who proposed it.
+func (s *Service) Approve(ctx context.Context, id, adminID uuid.UUID, note string) (*domain.MovementFreeze, error) {
+	f, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	prev, now := f.UpdatedAt, s.now()
+	var action domain.FreezeAction
+	switch {
+	case f.Status == domain.FreezePending:
+		if f.RequestedBy == adminID {
+			return nil, ErrSelfApproval
+		}
+		f.Status, f.ApprovedBy, f.ActivatedAt = domain.FreezeActive, &adminID, &now
+		action = domain.FreezeActionApproved
+	case f.Status == domain.FreezeActive && f.LiftRequestedBy != nil:
+		if *f.LiftRequestedBy == adminID {
+			return nil, ErrSelfApproval
+		}
+		f.Status, f.LiftedBy, f.LiftedAt = domain.FreezeLifted, &adminID, &now
+		action = domain.FreezeActionLifted
+	default:
+		return nil, ErrNothingToDo
+	}
+	f.UpdatedAt = now
+	if err := s.update(ctx, f, prev, action, adminID, note); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	s.logger.Warn("Money movement freeze "+string(action), map[string]interface{}{
+		"freeze_id": f.ID, "scope": f.Scope, "corridor": f.Corridor, "admin_id": adminID,
+	})
+	return f, nil
+}
+
+// Reject turns down what is awaiting approval. A rejected lift leaves the
+// freeze active. The proposer may reject their own proposal to withdraw it.
+func (s *Service) Reject(ctx context.Context, id, adminID uuid.UUID, note string) (*domain.MovementFreeze, error) {
+	f, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	prev := f.UpdatedAt
+	var action domain.FreezeAction
+	switch {
+	case f.Status == domain.FreezePending:
+		f.Status, f.RejectedBy = domain.FreezeRejected, &adminID
+		action = domain.FreezeActionRejected
+	case f.Status == domain.FreezeActive && f.LiftRequestedBy != nil:
+		f.LiftRequestedBy, f.LiftRequestedAt = nil, nil
+		action = domain.FreezeActionLiftRejected
+	default:
+		return nil, ErrNothingToDo
+	}
+	f.UpdatedAt = s.now()
+	if err := s.update(ctx, f, prev, action, adminID, note); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Get returns a freeze with its audit trail.
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.MovementFreeze, []domain.FreezeEvent, error) {
+	f, err := s.get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := s.repo.Events(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, events, nil
+}
+
+func (s *Service) List(ctx context.Context, status domain.FreezeStatus, limit, offset int) ([]domain.MovementFreeze, int, error) {
+	return s.repo.List(ctx, status, limit, offset)
+}
+
+// Check refuses m with pkgerrors.ErrMovementFrozen, carrying the
+// customer-facing message, when an active freeze covers it.
+func (s *Service) Check(ctx context.Context, m domain.Movement) error {
+	for _, f := range s.Active(ctx) {
+		if f.Covers(m) {
+			return fmt.Errorf("%w: %s", pkgerrors.ErrMovementFrozen, f.Message)
+		}
+	}
+	return nil
+}
+
+// Active returns the freezes in force, as of at most CacheTTL ago. When
+// they cannot be loaded the last known freezes stay in force.
+func (s *Service) Active(ctx context.Context) []domain.MovementFreeze {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fetchedAt.IsZero() && s.now().Sub(s.fetchedAt) < s.cfg.CacheTTL {
+		return s.active
+	}
+	open, err := s.repo.Open(ctx)
+	if err != nil {
+		s.logger.Error("Failed to load money movement freezes", map[string]interface{}{"error": err.Error()})
+		return s.active
+	}
+	active := make([]domain.MovementFreeze, 0, len(open))
+	for _, f := range open {
+		if f.Status == domain.FreezeActive {
+			active = append(active, f)
+		}
+	}
+	s.active, s.fetchedAt = active, s.now()
+	return active
+}
+
+// Status returns the status page view of the active freezes.
+func (s *Service) Status(ctx context.Context) *StatusPage {
+	page := &StatusPage{Status: "operational", Incidents: []Incident{}, UpdatedAt: s.now().UTC()}
+	for _, f := range s.Active(ctx) {
+		since := f.UpdatedAt
+		if f.ActivatedAt != nil {
+			since = *f.ActivatedAt
+		}
+		page.Incidents = append(page.Incidents, Incident{Scope: f.Scope, Corridor: f.Corridor, Message: f.Message, Since: since})
+	}
+	if len(page.Incidents) > 0 {
+		page.Status = "degraded"
+	}
+	return page
+}
+
+func (s *Service) get(ctx context.Context, id uuid.UUID) (*domain.MovementFreeze, error) {
+	f, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *Service) update(ctx context.Context, f *domain.MovementFreeze, prev time.Time, action domain.FreezeAction, adminID uuid.UUID, note string) error {
+	ok, err := s.repo.Update(ctx, f, prev, s.event(f, action, adminID, note))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *Service) event(f *domain.MovementFreeze, action domain.FreezeAction, adminID uuid.UUID, note string) *domain.FreezeEvent {
+	return &domain.FreezeEvent{
+		ID:        uuid.New(),
+		FreezeID:  f.ID,
+		Action:    action,
+		ActorID:   adminID,
+		Note:      strings.TrimSpace(note),
+		CreatedAt: f.UpdatedAt,
+	}
+}
+
+// invalidate makes the next check reload, so that an approval takes
+// effect at once in this process.
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.fetchedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+func defaultMessage(scope domain.FreezeScope, corridor string) string {
+	switch scope {
+	case domain.FreezeCrossBorder:
+		return "Cross-border payments are temporarily paused."
+	case domain.FreezeCorridor:
+		return fmt.Sprintf("Payments from %s to %s are temporarily paused.", corridor[:3], corridor[4:])
+	case domain.FreezeWithdrawals:
+		return "Withdrawals are temporarily paused."
+	}
+	return "Payments and withdrawals are temporarily paused."
+}
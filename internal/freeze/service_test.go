@@ -0,0 +1,283 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, f *domain.MovementFreeze, e *domain.FreezeEvent) error {
+	return m.Called(ctx, f, e).Error(0)
+}
+
+func (m *MockRepository) Get(ctx context.Context, id uuid.UUID) (*domain.MovementFreeze, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	// The service changes what it gets, as it would a freshly read row.
+	f := *args.Get(0).(*domain.MovementFreeze)
+	return &f, args.Error(1)
+}
+
+func (m *MockRepository) Update(ctx context.Context, f *domain.MovementFreeze, prev time.Time, e *domain.FreezeEvent) (bool, error) {
+	args := m.Called(ctx, f, prev, e)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Open(ctx context.Context) ([]domain.MovementFreeze, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.MovementFreeze), args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, status domain.FreezeStatus, limit, offset int) ([]domain.MovementFreeze, int, error) {
+	args := m.Called(ctx, status, limit, offset)
+	return args.Get(0).([]domain.MovementFreeze), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) Events(ctx context.Context, id uuid.UUID) ([]domain.FreezeEvent, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]domain.FreezeEvent), args.Error(1)
+}
+
+var start = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func newService(repo *MockRepository, now *time.Time) *Service {
+	s := NewService(repo, config.FreezeConfig{CacheTTL: 5 * time.Second}, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+// event matches the audit event recorded for action by actor.
+func event(action domain.FreezeAction, actor uuid.UUID) interface{} {
+	return mock.MatchedBy(func(e *domain.FreezeEvent) bool { return e.Action == action && e.ActorID == actor })
+}
+
+var (
+	mwkToZar   = domain.Movement{Kind: domain.MovementPayment, From: domain.MWK, To: domain.ZAR}
+	mwkToCny   = domain.Movement{Kind: domain.MovementSettlement, From: domain.MWK, To: domain.CNY}
+	domestic   = domain.Movement{Kind: domain.MovementPayment, From: domain.MWK, To: domain.MWK}
+	withdrawal = domain.Movement{Kind: domain.MovementWithdrawal, From: domain.MWK, To: domain.MWK}
+)
+
+func TestCovers(t *testing.T) {
+	cases := []struct {
+		freeze  domain.MovementFreeze
+		covered []domain.Movement
+		passed  []domain.Movement
+	}{
+		{domain.MovementFreeze{Scope: domain.FreezeAll}, []domain.Movement{mwkToZar, mwkToCny, domestic, withdrawal}, nil},
+		{domain.MovementFreeze{Scope: domain.FreezeCrossBorder}, []domain.Movement{mwkToZar, mwkToCny}, []domain.Movement{domestic, withdrawal}},
+		{domain.MovementFreeze{Scope: domain.FreezeCorridor, Corridor: "MWK-ZAR"}, []domain.Movement{mwkToZar}, []domain.Movement{mwkToCny, domestic, withdrawal}},
+		{domain.MovementFreeze{Scope: domain.FreezeWithdrawals}, []domain.Movement{withdrawal}, []domain.Movement{mwkToZar, domestic}},
+	}
+	for _, c := range cases {
+		for _, m := range c.covered {
+			assert.True(t, c.freeze.Covers(m), "%s should cover %+v", c.freeze.Scope, m)
+		}
+		for _, m := range c.passed {
+			assert.False(t, c.freeze.Covers(m), "%s should not cover %+v", c.freeze.Scope, m)
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	repo, now := &MockRepository{}, start
+	s := newService(repo, &now)
+	ctx, admin := context.Background(), uuid.New()
+	for _, req := range []Request{
+		{Scope: "everything", Reason: "incident"},
+		{Scope: domain.FreezeCorridor, Corridor: "MWK", Reason: "incident"},
+		{Scope: domain.FreezeCorridor, Corridor: "MWK-MWK", Reason: "incident"},
+		{Scope: domain.FreezeWithdrawals, Corridor: "MWK-ZAR", Reason: "incident"},
+		{Scope: domain.FreezeWithdrawals, Reason: "  "},
+	} {
+		_, err := s.Request(ctx, req, admin)
+		assert.ErrorIs(t, err, ErrInvalidFreeze, "%+v", req)
+	}
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+
+	repo.On("Open", ctx).Return([]domain.MovementFreeze{}, nil).Once()
+	repo.On("Create", ctx, mock.AnythingOfType("*domain.MovementFreeze"), event(domain.FreezeActionRequested, admin)).Return(nil).Once()
+	fr, err := s.Request(ctx, Request{Scope: domain.FreezeCorridor, Corridor: " mwk-zar ", Reason: "rail outage"}, admin)
+	require.NoError(t, err)
+	assert.Equal(t, "MWK-ZAR", fr.Corridor)
+	assert.Equal(t, domain.FreezePending, fr.Status)
+	assert.Equal(t, "Payments from MWK to ZAR are temporarily paused.", fr.Message)
+
+	repo.On("Open", ctx).Return([]domain.MovementFreeze{*fr}, nil).Once()
+	_, err = s.Request(ctx, Request{Scope: domain.FreezeCorridor, Corridor: "MWK-ZAR", Reason: "again"}, uuid.New())
+	assert.ErrorIs(t, err, ErrDuplicate)
+	repo.AssertExpectations(t)
+}
+
+func TestApprove(t *testing.T) {
+	repo, now := &MockRepository{}, start
+	s := newService(repo, &now)
+	ctx := context.Background()
+	maker, checker := uuid.New(), uuid.New()
+	pending := &domain.MovementFreeze{
+		ID: uuid.New(), Scope: domain.FreezeCrossBorder, Status: domain.FreezePending,
+		Message: "Cross-border payments are paused.", RequestedBy: maker, UpdatedAt: start.Add(-time.Hour),
+	}
+	repo.On("Get", ctx, pending.ID).Return(pending, nil)
+
+	repo.On("Open", ctx).Return([]domain.MovementFreeze{*pending}, nil).Once()
+	assert.NoError(t, s.Check(ctx, mwkToZar), "not enforced before approval")
+
+	_, err := s.Approve(ctx, pending.ID, maker, "")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+
+	repo.On("Update", ctx, mock.MatchedBy(func(f *domain.MovementFreeze) bool {
+		return f.Status == domain.FreezeActive && *f.ApprovedBy == checker && f.UpdatedAt.Equal(start)
+	}), pending.UpdatedAt, event(domain.FreezeActionApproved, checker)).Return(true, nil).Once()
+	fr, err := s.Approve(ctx, pending.ID, checker, "confirmed with compliance")
+	require.NoError(t, err)
+	assert.Equal(t, domain.FreezeActive, fr.Status)
+	assert.Equal(t, start, *fr.ActivatedAt)
+
+	// Enforced at once in this process despite the cache.
+	repo.On("Open", ctx).Return([]domain.MovementFreeze{*fr}, nil).Once()
+	err = s.Check(ctx, mwkToZar)
+	assert.ErrorIs(t, err, pkgerrors.ErrMovementFrozen)
+	assert.Contains(t, err.Error(), "Cross-border payments are paused.")
+	assert.NoError(t, s.Check(ctx, domestic))
+
+	page := s.Status(ctx)
+	assert.Equal(t, "degraded", page.Status)
+	require.Len(t, page.Incidents, 1)
+	assert.Equal(t, domain.FreezeCrossBorder, page.Incidents[0].Scope)
+	assert.Equal(t, start, page.Incidents[0].Since)
+	repo.AssertExpectations(t)
+}
+
+func TestLift(t *testing.T) {
+	repo, now := &MockRepository{}, start
+	s := newService(repo, &now)
+	ctx := context.Background()
+	maker, checker := uuid.New(), uuid.New()
+	activated := start.Add(-time.Hour)
+	active := &domain.MovementFreeze{
+		ID: uuid.New(), Scope: domain.FreezeAll, Status: domain.FreezeActive,
+		RequestedBy: maker, ApprovedBy: &checker, ActivatedAt: &activated, UpdatedAt: activated,
+	}
+	liftRequested := *active
+	liftRequested.LiftRequestedBy, liftRequested.LiftRequestedAt, liftRequested.UpdatedAt = &checker, &now, start
+
+	repo.On("Get", ctx, active.ID).Return(active, nil).Once()
+	_, err := s.Approve(ctx, active.ID, checker, "")
+	assert.ErrorIs(t, err, ErrNothingToDo, "no lift requested")
+
+	repo.On("Get", ctx, active.ID).Return(active, nil).Once()
+	repo.On("Update", ctx, mock.MatchedBy(func(f *domain.MovementFreeze) bool {
+		return f.Status == domain.FreezeActive && *f.LiftRequestedBy == checker
+	}), activated, event(domain.FreezeActionLiftRequested, checker)).Return(true, nil).Once()
+	fr, err := s.RequestLift(ctx, active.ID, checker, "rail restored")
+	require.NoError(t, err)
+	assert.Equal(t, &checker, fr.LiftRequestedBy)
+
+	repo.On("Get", ctx, active.ID).Return(&liftRequested, nil).Once()
+	_, err = s.Approve(ctx, active.ID, checker, "")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+
+	// A rejected lift leaves the freeze active.
+	repo.On("Get", ctx, active.ID).Return(&liftRequested, nil).Once()
+	repo.On("Update", ctx, mock.MatchedBy(func(f *domain.MovementFreeze) bool {
+		return f.Status == domain.FreezeActive && f.LiftRequestedBy == nil
+	}), start, event(domain.FreezeActionLiftRejected, maker)).Return(true, nil).Once()
+	fr, err = s.Reject(ctx, active.ID, maker, "not yet")
+	require.NoError(t, err)
+	assert.Equal(t, domain.FreezeActive, fr.Status)
+
+	now = now.Add(time.Minute)
+	repo.On("Get", ctx, active.ID).Return(&liftRequested, nil).Once()
+	repo.On("Update", ctx, mock.MatchedBy(func(f *domain.MovementFreeze) bool {
+		return f.Status == domain.FreezeLifted && *f.LiftedBy == maker && f.LiftedAt.Equal(now)
+	}), start, event(domain.FreezeActionLifted, maker)).Return(true, nil).Once()
+	fr, err = s.Approve(ctx, active.ID, maker, "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.FreezeLifted, fr.Status)
+
+	repo.On("Open", ctx).Return([]domain.MovementFreeze{}, nil).Once()
+	assert.NoError(t, s.Check(ctx, mwkToZar))
+	assert.Equal(t, "operational", s.Status(ctx).Status)
+
+	repo.On("Get", ctx, active.ID).Return(fr, nil).Once()
+	_, err = s.RequestLift(ctx, active.ID, checker, "")
+	assert.ErrorIs(t, err, ErrNotActive)
+	repo.AssertExpectations(t)
+}
+
+func TestRejectedFreezeNeverApplies(t *testing.T) {
+	repo, now := &MockRepository{}, start
+	s := newService(repo, &now)
+	ctx, maker := context.Background(), uuid.New()
+	pending := &domain.MovementFreeze{ID: uuid.New(), Scope: domain.FreezeWithdrawals, Status: domain.FreezePending, RequestedBy: maker, UpdatedAt: start}
+
+	repo.On("Get", ctx, pending.ID).Return(pending, nil).Once()
+	repo.On("Update", ctx, mock.MatchedBy(func(f *domain.MovementFreeze) bool {
+		return f.Status == domain.FreezeRejected && *f.RejectedBy == maker
+	}), start, event(domain.FreezeActionRejected, maker)).Return(true, nil).Once()
+	fr, err := s.Reject(ctx, pending.ID, maker, "withdrawn")
+	require.NoError(t, err)
+	assert.Equal(t, domain.FreezeRejected, fr.Status)
+
+	repo.On("Get", ctx, pending.ID).Return(fr, nil).Once()
+	_, err = s.Approve(ctx, pending.ID, uuid.New(), "")
+	assert.ErrorIs(t, err, ErrNothingToDo)
+
+	missing := uuid.New()
+	repo.On("Get", ctx, missing).Return(nil, nil).Once()
+	_, err = s.Approve(ctx, missing, uuid.New(), "")
+	assert.ErrorIs(t, err, ErrNotFound)
+	repo.AssertExpectations(t)
+}
+
+func TestConcurrentReview(t *testing.T) {
+	repo, now := &MockRepository{}, start
+	s := newService(repo, &now)
+	ctx := context.Background()
+	pending := &domain.MovementFreeze{ID: uuid.New(), Scope: domain.FreezeAll, Status: domain.FreezePending, RequestedBy: uuid.New(), UpdatedAt: start}
+
+	// Another admin rejected it between this approval's read and write.
+	repo.On("Get", ctx, pending.ID).Return(pending, nil).Once()
+	repo.On("Update", ctx, mock.Anything, start, mock.Anything).Return(false, nil).Once()
+	_, err := s.Approve(ctx, pending.ID, uuid.New(), "")
+	assert.ErrorIs(t, err, ErrConflict)
+	repo.AssertExpectations(t)
+}
+
+func TestActiveIsCachedAndSurvivesOutages(t *testing.T) {
+	repo, now := &MockRepository{}, start
+	s := newService(repo, &now)
+	ctx := context.Background()
+	active := domain.MovementFreeze{ID: uuid.New(), Scope: domain.FreezeAll, Status: domain.FreezeActive, Message: "Paused."}
+
+	repo.On("Open", ctx).Return([]domain.MovementFreeze{active}, nil).Once()
+	require.Error(t, s.Check(ctx, domestic))
+	require.Error(t, s.Check(ctx, withdrawal), "served from the cache")
+
+	// The last known freezes stay in force while they cannot be reloaded.
+	now = now.Add(time.Minute)
+	repo.On("Open", ctx).Return(nil, errors.New("database unavailable")).Once()
+	assert.ErrorIs(t, s.Check(ctx, domestic), pkgerrors.ErrMovementFrozen)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,87 @@
+package funding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/webhook"
+
+	"github.com/shopspring/decimal"
+)
+
+// MockProvider stands in for a real provider in development and tests. It
+// accepts every method and destination and never moves money; a deposit or
+// withdrawal completes when a callback signed with its secret is posted, as
+// test scripts do:
+//
+//	{"reference":"DEP-...","status":"succeeded","amount":"100.00","currency":"MWK"}
+//
+// signed in the KYD-Signature header the way outbound webhooks are.
+type MockProvider struct {
+	secret string
+	now    func() time.Time
+}
+
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{secret: secret, now: time.Now}
+}
+
+func (p *MockProvider) Name() string { return "mock" }
+
+func (p *MockProvider) Methods() []domain.FundingMethod {
+	return []domain.FundingMethod{domain.FundingMobileMoney, domain.FundingBankTransfer, domain.FundingCard}
+}
+
+func (p *MockProvider) Initiate(_ context.Context, c *Collection) (*Instructions, error) {
+	return &Instructions{
+		ProviderReference: "MOCK-" + c.Reference,
+		Message:           fmt.Sprintf("Mock %s collection of %s %s; post a signed callback to complete it", c.Method, c.Amount.StringFixed(2), c.Currency),
+	}, nil
+}
+
+type mockCallback struct {
+	Reference string          `json:"reference"`
+	Status    CallbackStatus  `json:"status"`
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  domain.Currency `json:"currency"`
+	Reason    string          `json:"reason"`
+}
+
+func (p *MockProvider) ParseCallback(_ context.Context, header http.Header, body []byte) (*CallbackResult, error) {
+	// An unset secret would make any caller able to sign.
+	if p.secret == "" {
+		return nil, ErrInvalidCallback
+	}
+	if err := webhook.Verify(p.secret, header.Get(webhook.HeaderSignature), body, p.now(), webhook.DefaultTolerance); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+	var cb mockCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+	return &CallbackResult{
+		Reference:         cb.Reference,
+		ProviderReference: "MOCK-" + cb.Reference,
+		Status:            cb.Status,
+		Amount:            cb.Amount,
+		Currency:          cb.Currency,
+		Reason:            cb.Reason,
+	}, nil
+}
+
+func (p *MockProvider) Destinations() []domain.PayoutDestinationType {
+	return []domain.PayoutDestinationType{domain.PayoutBankAccount, domain.PayoutMobileMoney}
+}
+
+func (p *MockProvider) Payout(_ context.Context, d *Disbursement) (*PayoutResult, error) {
+	return &PayoutResult{ProviderReference: "MOCK-" + d.Reference, Status: CallbackPending}, nil
+}
+
+// ParsePayoutCallback takes the same signed body as deposit callbacks.
+func (p *MockProvider) ParsePayoutCallback(ctx context.Context, header http.Header, body []byte) (*CallbackResult, error) {
+	return p.ParseCallback(ctx, header, body)
+}
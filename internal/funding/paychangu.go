@@ -0,0 +1,187 @@
+package funding
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+// PayChanguConfig configures the PayChangu hosted checkout.
+type PayChanguConfig struct {
+	BaseURL   string
+	SecretKey string
+	// WebhookSecret signs PayChangu's webhooks to us.
+	WebhookSecret string
+	// CallbackURL is where PayChangu posts webhooks, i.e. our
+	// /api/v1/deposits/callbacks/paychangu route.
+	CallbackURL string
+	// ReturnURL is where the payer's browser goes after checkout.
+	ReturnURL string
+	Timeout   time.Duration
+}
+
+// PayChanguProvider collects Malawian mobile money (Airtel Money, TNM
+// Mpamba) and card payments through PayChangu's hosted checkout. A webhook
+// only prompts us to look: the outcome credited is the one PayChangu's
+// verify endpoint reports for the reference.
+type PayChanguProvider struct {
+	cfg    PayChanguConfig
+	client *http.Client
+}
+
+func NewPayChanguProvider(cfg PayChanguConfig) *PayChanguProvider {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.paychangu.com"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &PayChanguProvider{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (p *PayChanguProvider) Name() string { return "paychangu" }
+
+func (p *PayChanguProvider) Methods() []domain.FundingMethod {
+	return []domain.FundingMethod{domain.FundingMobileMoney, domain.FundingCard}
+}
+
+type payChanguEnvelope struct {
+	Status  string          `json:"status"`
+	Message json.RawMessage `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type payChanguPayment struct {
+	TxRef     string          `json:"tx_ref"`
+	Reference string          `json:"reference"`
+	Status    string          `json:"status"`
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  domain.Currency `json:"currency"`
+}
+
+func (p *PayChanguProvider) Initiate(ctx context.Context, c *Collection) (*Instructions, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"amount":       c.Amount.StringFixed(2),
+		"currency":     c.Currency,
+		"tx_ref":       c.Reference,
+		"email":        c.Email,
+		"first_name":   c.FirstName,
+		"last_name":    c.LastName,
+		"callback_url": p.cfg.CallbackURL,
+		"return_url":   p.cfg.ReturnURL,
+		"customization": map[string]string{
+			"title":       "Wallet top-up",
+			"description": c.Reference,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		CheckoutURL string `json:"checkout_url"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/payment", body, &data); err != nil {
+		return nil, err
+	}
+	if data.CheckoutURL == "" {
+		return nil, fmt.Errorf("paychangu: no checkout url returned")
+	}
+	return &Instructions{
+		ProviderReference: c.Reference,
+		CheckoutURL:       data.CheckoutURL,
+		Message:           "Complete the payment on the PayChangu checkout page",
+	}, nil
+}
+
+func (p *PayChanguProvider) ParseCallback(ctx context.Context, header http.Header, body []byte) (*CallbackResult, error) {
+	if p.cfg.WebhookSecret == "" {
+		return nil, ErrInvalidCallback
+	}
+	want := payChanguSignature(p.cfg.WebhookSecret, body)
+	if !hmac.Equal([]byte(strings.ToLower(header.Get("Signature"))), []byte(want)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidCallback)
+	}
+	var hook struct {
+		TxRef string `json:"tx_ref"`
+	}
+	if err := json.Unmarshal(body, &hook); err != nil || hook.TxRef == "" {
+		return nil, fmt.Errorf("%w: no tx_ref", ErrInvalidCallback)
+	}
+
+	var payment payChanguPayment
+	if err := p.do(ctx, http.MethodGet, "/verify-payment/"+url.PathEscape(hook.TxRef), nil, &payment); err != nil {
+		return nil, err
+	}
+	res := &CallbackResult{
+		Reference:         hook.TxRef,
+		ProviderReference: payment.Reference,
+		Amount:            payment.Amount,
+		Currency:          payment.Currency,
+	}
+	switch strings.ToLower(payment.Status) {
+	case "success", "successful":
+		res.Status = CallbackSucceeded
+	case "failed", "cancelled", "canceled", "expired":
+		res.Status = CallbackFailed
+		res.Reason = "payment " + strings.ToLower(payment.Status)
+	default:
+		res.Status = CallbackPending
+	}
+	return res, nil
+}
+
+// payChanguSignature is the Signature header PayChangu sends: the hex
+// HMAC-SHA256 of the body under the webhook secret.
+func payChanguSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// do calls the PayChangu API and decodes the envelope's data into out.
+func (p *PayChanguProvider) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.cfg.SecretKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("paychangu %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("paychangu %s: %w", path, err)
+	}
+	var env payChanguEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return fmt.Errorf("paychangu %s: status %d: %w", path, resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 || env.Status != "success" {
+		return fmt.Errorf("paychangu %s: status %d: %s", path, resp.StatusCode, env.Message)
+	}
+	return json.Unmarshal(env.Data, out)
+}
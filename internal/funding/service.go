@@ -0,0 +1,376 @@
+// Package funding moves money between wallets and the outside world. A
+// deposit starts as a pending transaction handed to a payment provider;
+// the provider's callback confirms it and the wallet is credited through
+// the ledger. A withdrawal reserves its amount, waits for an admin when it
+// is large, and is paid out by a payout provider; the wallet is debited
+// when the payout is confirmed and the reservation returned if it fails.
+package funding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidDeposit      = errors.New("invalid deposit")
+	ErrWalletNotFound      = errors.New("wallet not found")
+	ErrNotWalletOwner      = errors.New("you are not allowed to top up this wallet")
+	ErrNoProvider          = errors.New("no payment provider accepts this deposit")
+	ErrUnknownProvider     = errors.New("unknown payment provider")
+	ErrProviderUnavailable = errors.New("payment provider unavailable")
+	ErrInvalidCallback     = errors.New("invalid provider callback")
+	ErrDepositNotFound     = errors.New("deposit not found")
+)
+
+// Metadata keys set on deposit transactions.
+const (
+	ProviderKey          = "funding_provider"
+	MethodKey            = "funding_method"
+	ProviderReferenceKey = "provider_reference"
+	InstructionsKey      = "funding_instructions"
+)
+
+// CallbackStatus is the outcome a provider reports for a collection.
+type CallbackStatus string
+
+const (
+	CallbackSucceeded CallbackStatus = "succeeded"
+	CallbackFailed    CallbackStatus = "failed"
+	CallbackPending   CallbackStatus = "pending"
+)
+
+// Collection asks a provider to collect a deposit from the customer.
+type Collection struct {
+	Reference string
+	Method    domain.FundingMethod
+	Amount    decimal.Decimal
+	Currency  domain.Currency
+	// Payer details; Phone is the mobile money account to charge.
+	Phone     string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// Instructions tell the customer how to complete a deposit.
+type Instructions struct {
+	ProviderReference string `json:"provider_reference,omitempty"`
+	// CheckoutURL is a hosted page where card and mobile money payers
+	// approve the payment.
+	CheckoutURL string `json:"checkout_url,omitempty"`
+	// Message is shown to the customer, e.g. to approve a USSD prompt or
+	// the bank details and reference to pay to.
+	Message string `json:"message,omitempty"`
+}
+
+// CallbackResult is what an authenticated provider callback reports.
+type CallbackResult struct {
+	// Reference is the deposit transaction's reference.
+	Reference         string
+	ProviderReference string
+	Status            CallbackStatus
+	Amount            decimal.Decimal
+	Currency          domain.Currency
+	Reason            string
+}
+
+// Provider collects deposits through an external payment network.
+type Provider interface {
+	Name() string
+	Methods() []domain.FundingMethod
+	// Initiate starts collecting c.
+	Initiate(ctx context.Context, c *Collection) (*Instructions, error)
+	// ParseCallback authenticates a callback and reports the outcome it
+	// carries, returning ErrInvalidCallback for anything not from the
+	// provider.
+	ParseCallback(ctx context.Context, header http.Header, body []byte) (*CallbackResult, error)
+}
+
+type TransactionRepository interface {
+	Create(ctx context.Context, tx *domain.Transaction) error
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
+	FindByReference(ctx context.Context, ref string) (*domain.Transaction, error)
+	// FindByType lists transactions of txType, newest first, filtered by
+	// status when it is non-empty, with the total matching.
+	FindByType(ctx context.Context, txType domain.TransactionType, status domain.TransactionStatus, limit, offset int) ([]*domain.Transaction, int, error)
+	SetMetadata(ctx context.Context, id uuid.UUID, metadata domain.Metadata) error
+	TransitionStatus(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error)
+	// GetDailyTotal sums what userID sent in currency over the last 24
+	// hours, withdrawals included.
+	GetDailyTotal(ctx context.Context, userID uuid.UUID, currency domain.Currency) (decimal.Decimal, error)
+}
+
+type WalletRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error)
+	// ReserveFunds moves amount from the available to the reserved
+	// balance, failing with ErrInsufficientBalance when it is not there.
+	ReserveFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error
+}
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// Ledger credits confirmed deposits and debits or releases withdrawals;
+// satisfied by ledger.Service.
+type Ledger interface {
+	PostFunding(ctx context.Context, p *ledger.FundingPosting) (bool, error)
+	PostWithdrawal(ctx context.Context, p *ledger.FundingPosting) (bool, error)
+	CancelWithdrawal(ctx context.Context, p *ledger.FundingPosting, reason string) (bool, error)
+	ReleaseWithdrawal(ctx context.Context, p *ledger.FundingPosting, reason string) (bool, error)
+}
+
+type Service struct {
+	txs               TransactionRepository
+	wallets           WalletRepository
+	users             UserRepository
+	ledger            Ledger
+	providers         []Provider
+	payouts           []PayoutProvider
+	approvalThreshold decimal.Decimal
+	freezes           FreezeChecker
+	blocklist         Blocklist
+	controls          SpendingControlRepository
+	dailyLimit        DailyLimit
+	logger            logger.Logger
+}
+
+func NewService(txs TransactionRepository, wallets WalletRepository, users UserRepository, l Ledger, log logger.Logger) *Service {
+	return &Service{txs: txs, wallets: wallets, users: users, ledger: l, logger: log}
+}
+
+// WithProvider adds a provider. Deposits go to the first provider added
+// that supports their method unless the customer names one.
+func (s *Service) WithProvider(p Provider) *Service {
+	s.providers = append(s.providers, p)
+	return s
+}
+
+// DepositRequest is a customer's request to top up a wallet.
+type DepositRequest struct {
+	Amount   decimal.Decimal      `json:"amount"`
+	Currency domain.Currency      `json:"currency"`
+	Method   domain.FundingMethod `json:"method"`
+	// Provider picks a provider by name; empty picks the first that
+	// supports Method.
+	Provider string `json:"provider,omitempty"`
+	// Phone is the mobile money account to charge; defaults to the
+	// wallet owner's phone.
+	Phone string `json:"phone,omitempty"`
+}
+
+// Deposit is a started top-up and how the customer completes it.
+type Deposit struct {
+	Transaction  *domain.Transaction `json:"transaction"`
+	Provider     string              `json:"provider"`
+	Instructions *Instructions       `json:"instructions"`
+}
+
+func (s *Service) provider(name string, method domain.FundingMethod) Provider {
+	for _, p := range s.providers {
+		if name != "" && p.Name() != name {
+			continue
+		}
+		for _, m := range p.Methods() {
+			if m == method {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// Initiate records a pending deposit into walletID and asks a provider to
+// collect it. Only the wallet's owner, or an admin, may top it up.
+func (s *Service) Initiate(ctx context.Context, walletID, userID uuid.UUID, admin bool, req DepositRequest) (*Deposit, error) {
+	if !req.Amount.IsPositive() || req.Amount.Exponent() < -2 {
+		return nil, fmt.Errorf("%w: amount must be positive with at most two decimal places", ErrInvalidDeposit)
+	}
+	if !req.Method.Valid() {
+		return nil, fmt.Errorf("%w: method must be mobile_money, bank_transfer or card", ErrInvalidDeposit)
+	}
+	w, err := s.wallets.FindByID(ctx, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+	if !admin && w.UserID != userID {
+		return nil, ErrNotWalletOwner
+	}
+	if w.Currency != req.Currency {
+		return nil, fmt.Errorf("%w: wallet holds %s", ErrInvalidDeposit, w.Currency)
+	}
+	if w.Status != domain.WalletStatusActive {
+		return nil, fmt.Errorf("%w: wallet is %s", ErrInvalidDeposit, w.Status)
+	}
+	owner, err := s.users.FindByID(ctx, w.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("find wallet owner: %w", err)
+	}
+	if owner.KYCStatus != domain.KYCStatusVerified {
+		return nil, fmt.Errorf("%w: wallet owner is not KYC verified", ErrInvalidDeposit)
+	}
+	p := s.provider(req.Provider, req.Method)
+	if p == nil {
+		if req.Provider != "" {
+			return nil, fmt.Errorf("%w: %s does not take %s", ErrNoProvider, req.Provider, req.Method)
+		}
+		return nil, ErrNoProvider
+	}
+	phone := req.Phone
+	if phone == "" {
+		phone = owner.Phone
+	}
+	if req.Method == domain.FundingMobileMoney && phone == "" {
+		return nil, fmt.Errorf("%w: a phone number is needed for mobile money", ErrInvalidDeposit)
+	}
+
+	now := time.Now()
+	id := uuid.New()
+	tx := &domain.Transaction{
+		ID: id,
+		// Providers echo the reference in their callbacks.
+		Reference:         fmt.Sprintf("DEP-%s", id),
+		SenderID:          w.UserID,
+		ReceiverID:        w.UserID,
+		SenderWalletID:    &w.ID,
+		ReceiverWalletID:  &w.ID,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		ExchangeRate:      decimal.NewFromInt(1),
+		ConvertedAmount:   req.Amount,
+		ConvertedCurrency: req.Currency,
+		NetAmount:         req.Amount,
+		Status:            domain.TransactionStatusPending,
+		TransactionType:   domain.TransactionTypeDeposit,
+		// The method is kept in metadata; channel is where the request
+		// came from and is limited to the customer channels.
+		Channel:     "api",
+		Category:    "top_up",
+		Description: fmt.Sprintf("Top-up via %s", p.Name()),
+		Metadata:    domain.Metadata{ProviderKey: p.Name(), MethodKey: string(req.Method)},
+		InitiatedAt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.txs.Create(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	instructions, err := p.Initiate(ctx, &Collection{
+		Reference: tx.Reference,
+		Method:    req.Method,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Phone:     phone,
+		Email:     owner.Email,
+		FirstName: owner.FirstName,
+		LastName:  owner.LastName,
+	})
+	if err != nil {
+		s.logger.Error("Deposit provider rejected collection", map[string]interface{}{
+			"provider": p.Name(), "transaction_id": tx.ID, "error": err.Error(),
+		})
+		s.fail(ctx, tx, "provider rejected the deposit")
+		return nil, ErrProviderUnavailable
+	}
+	tx.Metadata[ProviderReferenceKey] = instructions.ProviderReference
+	tx.Metadata[InstructionsKey] = instructions
+	if err := s.txs.SetMetadata(ctx, tx.ID, tx.Metadata); err != nil {
+		// The callback finds the deposit by our reference, so it still
+		// completes; only the instructions are not kept.
+		s.logger.Error("Failed to store deposit instructions", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+	}
+	return &Deposit{Transaction: tx, Provider: p.Name(), Instructions: instructions}, nil
+}
+
+// HandleCallback applies a callback from the named provider. Callbacks are
+// safe to repeat: a deposit is credited or failed once and later callbacks
+// for it are acknowledged without effect.
+func (s *Service) HandleCallback(ctx context.Context, providerName string, header http.Header, body []byte) error {
+	var p Provider
+	for _, candidate := range s.providers {
+		if candidate.Name() == providerName {
+			p = candidate
+		}
+	}
+	if p == nil {
+		return ErrUnknownProvider
+	}
+	res, err := p.ParseCallback(ctx, header, body)
+	if err != nil {
+		return err
+	}
+	tx, err := s.txs.FindByReference(ctx, res.Reference)
+	if err != nil || tx.TransactionType != domain.TransactionTypeDeposit || tx.Metadata[ProviderKey] != p.Name() {
+		return ErrDepositNotFound
+	}
+	if tx.Status != domain.TransactionStatusPending {
+		return nil
+	}
+
+	switch res.Status {
+	case CallbackPending:
+		return nil
+	case CallbackFailed:
+		reason := res.Reason
+		if reason == "" {
+			reason = "payment not completed"
+		}
+		s.fail(ctx, tx, reason)
+		return nil
+	case CallbackSucceeded:
+	default:
+		return fmt.Errorf("%w: status %q", ErrInvalidCallback, res.Status)
+	}
+
+	// The customer may pay a different amount than asked, e.g. at a bank
+	// counter; crediting what was asked would be wrong either way.
+	if !res.Amount.Equal(tx.Amount) || res.Currency != tx.Currency {
+		s.logger.Error("Deposit callback amount mismatch", map[string]interface{}{
+			"transaction_id": tx.ID,
+			"expected":       tx.Amount.String() + " " + string(tx.Currency),
+			"received":       res.Amount.String() + " " + string(res.Currency),
+		})
+		return fmt.Errorf("%w: paid %s %s for a deposit of %s %s", ErrInvalidCallback, res.Amount, res.Currency, tx.Amount, tx.Currency)
+	}
+
+	metadata := domain.Metadata{}
+	if res.ProviderReference != "" {
+		metadata[ProviderReferenceKey] = res.ProviderReference
+	}
+	credited, err := s.ledger.PostFunding(ctx, &ledger.FundingPosting{
+		TransactionID: tx.ID,
+		WalletID:      *tx.ReceiverWalletID,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		Metadata:      metadata,
+	})
+	if err != nil {
+		return err
+	}
+	if credited {
+		s.logger.Info("Deposit credited", map[string]interface{}{
+			"transaction_id": tx.ID, "wallet_id": *tx.ReceiverWalletID, "amount": tx.Amount, "currency": tx.Currency,
+		})
+	}
+	return nil
+}
+
+func (s *Service) fail(ctx context.Context, tx *domain.Transaction, reason string) {
+	tx.Status = domain.TransactionStatusFailed
+	tx.StatusReason = reason
+	tx.UpdatedAt = time.Now()
+	if _, err := s.txs.TransitionStatus(ctx, tx, domain.TransactionStatusPending); err != nil {
+		s.logger.Error("Failed to fail deposit", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+	}
+}
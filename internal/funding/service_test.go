@@ -0,0 +1,506 @@
+package funding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	"kyd/internal/webhook"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type memTxs struct {
+	byRef map[string]*domain.Transaction
+}
+
+func (m *memTxs) Create(ctx context.Context, tx *domain.Transaction) error {
+	m.byRef[tx.Reference] = tx
+	return nil
+}
+
+func (m *memTxs) FindByReference(ctx context.Context, ref string) (*domain.Transaction, error) {
+	tx, ok := m.byRef[ref]
+	if !ok {
+		return nil, pkgerrors.ErrTransactionNotFound
+	}
+	cp := *tx
+	return &cp, nil
+}
+
+func (m *memTxs) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	for _, tx := range m.byRef {
+		if tx.ID == id {
+			cp := *tx
+			return &cp, nil
+		}
+	}
+	return nil, pkgerrors.ErrTransactionNotFound
+}
+
+func (m *memTxs) FindByType(ctx context.Context, txType domain.TransactionType, status domain.TransactionStatus, limit, offset int) ([]*domain.Transaction, int, error) {
+	var out []*domain.Transaction
+	for _, tx := range m.byRef {
+		if tx.TransactionType == txType && (status == "" || tx.Status == status) {
+			out = append(out, tx)
+		}
+	}
+	return out, len(out), nil
+}
+
+func (m *memTxs) SetMetadata(ctx context.Context, id uuid.UUID, metadata domain.Metadata) error {
+	return nil
+}
+
+func (m *memTxs) TransitionStatus(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error) {
+	stored := m.byRef[tx.Reference]
+	if stored.Status != from {
+		return false, nil
+	}
+	stored.Status, stored.StatusReason = tx.Status, tx.StatusReason
+	return true, nil
+}
+
+func (m *memTxs) GetDailyTotal(ctx context.Context, userID uuid.UUID, currency domain.Currency) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, tx := range m.byRef {
+		if tx.SenderID == userID && tx.Currency == currency &&
+			tx.Status != domain.TransactionStatusFailed && tx.Status != domain.TransactionStatusCancelled {
+			total = total.Add(tx.Amount)
+		}
+	}
+	return total, nil
+}
+
+type memWallets map[uuid.UUID]*domain.Wallet
+
+func (m memWallets) FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	w, ok := m[id]
+	if !ok {
+		return nil, pkgerrors.ErrWalletNotFound
+	}
+	return w, nil
+}
+
+func (m memWallets) ReserveFunds(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	w := m[id]
+	if w.AvailableBalance.LessThan(amount) {
+		return pkgerrors.ErrInsufficientBalance
+	}
+	w.AvailableBalance = w.AvailableBalance.Sub(amount)
+	w.ReservedBalance = w.ReservedBalance.Add(amount)
+	return nil
+}
+
+type memUsers map[uuid.UUID]*domain.User
+
+func (m memUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	return m[id], nil
+}
+
+// memLedger credits wallets the way ledger.PostFunding does: once, and only
+// while the transaction is pending.
+type memLedger struct {
+	txs     *memTxs
+	wallets memWallets
+	posts   int
+}
+
+func (m *memLedger) PostFunding(ctx context.Context, p *ledger.FundingPosting) (bool, error) {
+	for _, tx := range m.txs.byRef {
+		if tx.ID == p.TransactionID && tx.Status == domain.TransactionStatusPending {
+			tx.Status = domain.TransactionStatusCompleted
+			w := m.wallets[p.WalletID]
+			w.AvailableBalance = w.AvailableBalance.Add(p.Amount)
+			m.posts++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memLedger) PostWithdrawal(ctx context.Context, p *ledger.FundingPosting) (bool, error) {
+	for _, tx := range m.txs.byRef {
+		if tx.ID == p.TransactionID && tx.Status == domain.TransactionStatusProcessing {
+			tx.Status = domain.TransactionStatusCompleted
+			w := m.wallets[p.WalletID]
+			w.ReservedBalance = w.ReservedBalance.Sub(p.Amount)
+			w.LedgerBalance = w.LedgerBalance.Sub(p.Amount)
+			m.posts++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memLedger) CancelWithdrawal(ctx context.Context, p *ledger.FundingPosting, reason string) (bool, error) {
+	return m.cancel(p, reason, domain.TransactionStatusPending, domain.TransactionStatusPendingApproval, domain.TransactionStatusProcessing)
+}
+
+func (m *memLedger) ReleaseWithdrawal(ctx context.Context, p *ledger.FundingPosting, reason string) (bool, error) {
+	return m.cancel(p, reason, domain.TransactionStatusPending)
+}
+
+func (m *memLedger) cancel(p *ledger.FundingPosting, reason string, from ...domain.TransactionStatus) (bool, error) {
+	for _, tx := range m.txs.byRef {
+		if tx.ID != p.TransactionID {
+			continue
+		}
+		for _, status := range from {
+			if tx.Status == status {
+				tx.Status, tx.StatusReason = domain.TransactionStatusFailed, reason
+				w := m.wallets[p.WalletID]
+				w.AvailableBalance = w.AvailableBalance.Add(p.Amount)
+				w.ReservedBalance = w.ReservedBalance.Sub(p.Amount)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+type fixture struct {
+	svc    *Service
+	txs    *memTxs
+	ledger *memLedger
+	wallet *domain.Wallet
+	owner  *domain.User
+	secret string
+}
+
+func newFixture(t *testing.T) *fixture {
+	owner := &domain.User{ID: uuid.New(), Phone: "+265991234567", KYCStatus: domain.KYCStatusVerified}
+	w := &domain.Wallet{ID: uuid.New(), UserID: owner.ID, Currency: domain.MWK, Status: domain.WalletStatusActive}
+	txs := &memTxs{byRef: map[string]*domain.Transaction{}}
+	wallets := memWallets{w.ID: w}
+	l := &memLedger{txs: txs, wallets: wallets}
+	mock := NewMockProvider("s3cret")
+	svc := NewService(txs, wallets, memUsers{owner.ID: owner}, l, logger.NewNop()).
+		WithProvider(mock).
+		WithPayoutProvider(mock).
+		WithApprovalThreshold(decimal.NewFromInt(100000))
+	return &fixture{svc: svc, txs: txs, ledger: l, wallet: w, owner: owner, secret: "s3cret"}
+}
+
+func (f *fixture) callback(t *testing.T, body map[string]interface{}) error {
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	h := http.Header{}
+	h.Set(webhook.HeaderSignature, webhook.Sign(f.secret, time.Now(), raw))
+	return f.svc.HandleCallback(context.Background(), "mock", h, raw)
+}
+
+func (f *fixture) payoutCallback(t *testing.T, body map[string]interface{}) error {
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	h := http.Header{}
+	h.Set(webhook.HeaderSignature, webhook.Sign(f.secret, time.Now(), raw))
+	return f.svc.HandlePayoutCallback(context.Background(), "mock", h, raw)
+}
+
+func TestInitiate(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	req := DepositRequest{Amount: decimal.NewFromInt(5000), Currency: domain.MWK, Method: domain.FundingMobileMoney}
+
+	d, err := f.svc.Initiate(ctx, f.wallet.ID, f.owner.ID, false, req)
+	require.NoError(t, err)
+	assert.Equal(t, "mock", d.Provider)
+	assert.Equal(t, domain.TransactionStatusPending, d.Transaction.Status)
+	assert.Equal(t, domain.TransactionTypeDeposit, d.Transaction.TransactionType)
+	assert.Equal(t, "MOCK-"+d.Transaction.Reference, d.Instructions.ProviderReference)
+	assert.True(t, f.wallet.AvailableBalance.IsZero(), "nothing is credited until the provider confirms")
+
+	_, err = f.svc.Initiate(ctx, f.wallet.ID, uuid.New(), false, req)
+	assert.ErrorIs(t, err, ErrNotWalletOwner)
+	_, err = f.svc.Initiate(ctx, f.wallet.ID, uuid.New(), true, req)
+	assert.NoError(t, err, "admins may top up any wallet")
+
+	for name, mutate := range map[string]func(*DepositRequest){
+		"currency": func(r *DepositRequest) { r.Currency = domain.ZMW },
+		"amount":   func(r *DepositRequest) { r.Amount = decimal.RequireFromString("10.005") },
+		"method":   func(r *DepositRequest) { r.Method = "cash" },
+	} {
+		bad := req
+		mutate(&bad)
+		_, err := f.svc.Initiate(ctx, f.wallet.ID, f.owner.ID, false, bad)
+		assert.ErrorIs(t, err, ErrInvalidDeposit, name)
+	}
+
+	bad := req
+	bad.Provider = "paychangu"
+	_, err = f.svc.Initiate(ctx, f.wallet.ID, f.owner.ID, false, bad)
+	assert.ErrorIs(t, err, ErrNoProvider)
+}
+
+func TestHandleCallback(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	d, err := f.svc.Initiate(ctx, f.wallet.ID, f.owner.ID, false, DepositRequest{Amount: decimal.NewFromInt(5000), Currency: domain.MWK, Method: domain.FundingCard})
+	require.NoError(t, err)
+	ref := d.Transaction.Reference
+
+	f.secret = "wrong"
+	assert.ErrorIs(t, f.callback(t, map[string]interface{}{"reference": ref, "status": "succeeded", "amount": "5000", "currency": "MWK"}), ErrInvalidCallback)
+	f.secret = "s3cret"
+
+	assert.ErrorIs(t, f.callback(t, map[string]interface{}{"reference": ref, "status": "succeeded", "amount": "4000", "currency": "MWK"}), ErrInvalidCallback)
+	assert.ErrorIs(t, f.callback(t, map[string]interface{}{"reference": "DEP-unknown", "status": "succeeded", "amount": "5000", "currency": "MWK"}), ErrDepositNotFound)
+	require.NoError(t, f.callback(t, map[string]interface{}{"reference": ref, "status": "pending"}))
+	assert.True(t, f.wallet.AvailableBalance.IsZero())
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, f.callback(t, map[string]interface{}{"reference": ref, "status": "succeeded", "amount": "5000.00", "currency": "MWK"}))
+	}
+	assert.Equal(t, 1, f.ledger.posts, "a repeated callback credits once")
+	assert.True(t, f.wallet.AvailableBalance.Equal(decimal.NewFromInt(5000)))
+
+	require.NoError(t, f.callback(t, map[string]interface{}{"reference": ref, "status": "failed"}))
+	assert.Equal(t, domain.TransactionStatusCompleted, f.txs.byRef[ref].Status, "a late failure does not undo a credited deposit")
+
+	assert.ErrorIs(t, f.svc.HandleCallback(ctx, "unknown", http.Header{}, nil), ErrUnknownProvider)
+}
+
+func TestHandleCallback_Failed(t *testing.T) {
+	f := newFixture(t)
+	d, err := f.svc.Initiate(context.Background(), f.wallet.ID, f.owner.ID, false, DepositRequest{Amount: decimal.NewFromInt(10), Currency: domain.MWK, Method: domain.FundingBankTransfer})
+	require.NoError(t, err)
+
+	require.NoError(t, f.callback(t, map[string]interface{}{"reference": d.Transaction.Reference, "status": "failed", "reason": "declined"}))
+	tx := f.txs.byRef[d.Transaction.Reference]
+	assert.Equal(t, domain.TransactionStatusFailed, tx.Status)
+	assert.Equal(t, "declined", tx.StatusReason)
+	assert.Zero(t, f.ledger.posts)
+}
+
+func TestPayChanguProvider(t *testing.T) {
+	var paid string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/payment":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "DEP-1", body["tx_ref"])
+			assert.Equal(t, "2500.00", body["amount"])
+			w.Write([]byte(`{"status":"success","message":"Hosted payment session generated","data":{"checkout_url":"https://checkout.example/abc"}}`))
+		case "/verify-payment/DEP-1":
+			w.Write([]byte(`{"status":"success","data":{"tx_ref":"DEP-1","reference":"PC-778","status":"` + paid + `","amount":2500,"currency":"MWK"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewPayChanguProvider(PayChanguConfig{BaseURL: srv.URL, SecretKey: "sk-test", WebhookSecret: "whsec"})
+	in, err := p.Initiate(context.Background(), &Collection{Reference: "DEP-1", Method: domain.FundingCard, Amount: decimal.NewFromInt(2500), Currency: domain.MWK})
+	require.NoError(t, err)
+	assert.Equal(t, "https://checkout.example/abc", in.CheckoutURL)
+
+	body := []byte(`{"tx_ref":"DEP-1","status":"success","amount":999999}`)
+	_, err = p.ParseCallback(context.Background(), http.Header{"Signature": {"deadbeef"}}, body)
+	assert.ErrorIs(t, err, ErrInvalidCallback)
+
+	h := http.Header{}
+	h.Set("Signature", payChanguSignature("whsec", body))
+	paid = "success"
+	res, err := p.ParseCallback(context.Background(), h, body)
+	require.NoError(t, err)
+	assert.Equal(t, CallbackSucceeded, res.Status)
+	assert.True(t, res.Amount.Equal(decimal.NewFromInt(2500)), "the verified amount is used, not the webhook's")
+	assert.Equal(t, "PC-778", res.ProviderReference)
+
+	paid = "failed"
+	res, err = p.ParseCallback(context.Background(), h, body)
+	require.NoError(t, err)
+	assert.Equal(t, CallbackFailed, res.Status)
+}
+
+var mobileMoney = domain.PayoutDestination{Type: domain.PayoutMobileMoney, AccountName: "Chikondi Banda", Phone: "+265 991 234 567"}
+
+func TestWithdraw(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	f.wallet.AvailableBalance = decimal.NewFromInt(10000)
+	f.wallet.LedgerBalance = decimal.NewFromInt(10000)
+	req := WithdrawRequest{Amount: decimal.NewFromInt(4000), Currency: domain.MWK, Destination: mobileMoney}
+
+	for name, mutate := range map[string]func(*WithdrawRequest){
+		"amount":   func(r *WithdrawRequest) { r.Amount = decimal.Zero },
+		"currency": func(r *WithdrawRequest) { r.Currency = domain.ZMW },
+		"destination": func(r *WithdrawRequest) {
+			r.Destination = domain.PayoutDestination{Type: domain.PayoutBankAccount, AccountName: "C Banda"}
+		},
+		"type": func(r *WithdrawRequest) { r.Destination.Type = "cash" },
+	} {
+		bad := req
+		mutate(&bad)
+		_, err := f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, bad)
+		assert.ErrorIs(t, err, ErrInvalidWithdrawal, name)
+	}
+	_, err := f.svc.Withdraw(ctx, f.wallet.ID, uuid.New(), req)
+	assert.ErrorIs(t, err, ErrNotWalletOwner)
+	big := req
+	big.Amount = decimal.NewFromInt(20000)
+	_, err = f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, big)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	wd, err := f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+	require.NoError(t, err)
+	assert.False(t, wd.RequiresApproval)
+	assert.Equal(t, domain.TransactionStatusProcessing, wd.Transaction.Status)
+	assert.Equal(t, "+265991234567", wd.Transaction.Metadata[DestinationKey].(domain.PayoutDestination).Phone)
+	assert.True(t, f.wallet.AvailableBalance.Equal(decimal.NewFromInt(6000)), "the amount is reserved at once")
+	assert.True(t, f.wallet.ReservedBalance.Equal(decimal.NewFromInt(4000)))
+
+	ref := wd.Transaction.Reference
+	assert.ErrorIs(t, f.payoutCallback(t, map[string]interface{}{"reference": ref, "status": "succeeded", "amount": "400", "currency": "MWK"}), ErrInvalidCallback)
+	assert.ErrorIs(t, f.callback(t, map[string]interface{}{"reference": ref, "status": "succeeded", "amount": "4000", "currency": "MWK"}), ErrDepositNotFound,
+		"a withdrawal cannot be completed through the deposit callback")
+	for i := 0; i < 2; i++ {
+		require.NoError(t, f.payoutCallback(t, map[string]interface{}{"reference": ref, "status": "succeeded", "amount": "4000", "currency": "MWK"}))
+	}
+	assert.Equal(t, 1, f.ledger.posts, "a repeated callback debits once")
+	assert.True(t, f.wallet.ReservedBalance.IsZero())
+	assert.True(t, f.wallet.LedgerBalance.Equal(decimal.NewFromInt(6000)))
+
+	wd, err = f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+	require.NoError(t, err)
+	require.NoError(t, f.payoutCallback(t, map[string]interface{}{"reference": wd.Transaction.Reference, "status": "failed", "reason": "recipient unregistered"}))
+	tx := f.txs.byRef[wd.Transaction.Reference]
+	assert.Equal(t, domain.TransactionStatusFailed, tx.Status)
+	assert.Equal(t, "recipient unregistered", tx.StatusReason)
+	assert.True(t, f.wallet.AvailableBalance.Equal(decimal.NewFromInt(6000)), "a failed payout returns the reservation")
+	assert.True(t, f.wallet.ReservedBalance.IsZero())
+}
+
+type mockScreening struct {
+	mock.Mock
+}
+
+func (m *mockScreening) IsBlacklisted(ctx context.Context, value string) (bool, error) {
+	args := m.Called(ctx, value)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockScreening) Get(ctx context.Context, userID uuid.UUID) (*domain.SpendingControls, error) {
+	args := m.Called(ctx, userID)
+	c, _ := args.Get(0).(*domain.SpendingControls)
+	return c, args.Error(1)
+}
+
+func (m *mockScreening) CheckDailyLimit(amount, dailyTotal decimal.Decimal) error {
+	return m.Called(amount, dailyTotal).Error(0)
+}
+
+func TestWithdraw_Screening(t *testing.T) {
+	ctx := context.Background()
+	req := WithdrawRequest{Amount: decimal.NewFromInt(4000), Currency: domain.MWK, Destination: mobileMoney}
+	limit := func(v int64) *decimal.Decimal { d := decimal.NewFromInt(v); return &d }
+	setup := func(blocked bool, controls *domain.SpendingControls, overLimit error) (*fixture, *mockScreening) {
+		f := newFixture(t)
+		f.wallet.AvailableBalance = decimal.NewFromInt(10000)
+		m := &mockScreening{}
+		m.On("IsBlacklisted", ctx, f.owner.ID.String()).Return(blocked, nil).Maybe()
+		m.On("Get", ctx, f.owner.ID).Return(controls, nil).Maybe()
+		m.On("CheckDailyLimit", mock.Anything, mock.Anything).Return(overLimit).Maybe()
+		f.svc.WithScreening(m, m, m)
+		return f, m
+	}
+
+	for name, tc := range map[string]struct {
+		blocked   bool
+		controls  *domain.SpendingControls
+		overLimit error
+	}{
+		"blocklisted":     {blocked: true},
+		"frozen":          {controls: &domain.SpendingControls{Frozen: true}},
+		"per-transaction": {controls: &domain.SpendingControls{MaxPerTransaction: limit(3000)}},
+		"daily cap":       {controls: &domain.SpendingControls{DailyCap: limit(3999)}},
+		"daily limit":     {overLimit: errors.New("transaction exceeds daily limit of 3000")},
+	} {
+		f, _ := setup(tc.blocked, tc.controls, tc.overLimit)
+		_, err := f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+		assert.ErrorIs(t, err, ErrWithdrawalRefused, name)
+		assert.Empty(t, f.txs.byRef, name)
+		assert.True(t, f.wallet.ReservedBalance.IsZero(), name)
+	}
+
+	f, m := setup(false, &domain.SpendingControls{DailyCap: limit(6000)}, nil)
+	_, err := f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+	require.NoError(t, err)
+	m.AssertCalled(t, "CheckDailyLimit", req.Amount, decimal.Zero)
+	_, err = f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+	assert.ErrorIs(t, err, ErrWithdrawalRefused, "the first withdrawal counts towards the cap")
+}
+
+func TestWithdraw_Approval(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	admin := uuid.New()
+	f.wallet.AvailableBalance = decimal.NewFromInt(500000)
+	req := WithdrawRequest{Amount: decimal.NewFromInt(150000), Currency: domain.MWK,
+		Destination: domain.PayoutDestination{Type: domain.PayoutBankAccount, AccountName: "Chikondi Banda", BankCode: "NBM", AccountNumber: "1001 234 567"}}
+
+	wd, err := f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+	require.NoError(t, err)
+	assert.True(t, wd.RequiresApproval)
+	assert.Equal(t, domain.TransactionStatusPendingApproval, wd.Transaction.Status)
+	assert.True(t, f.wallet.ReservedBalance.Equal(decimal.NewFromInt(150000)), "held withdrawals keep their reservation")
+
+	queue, total, err := f.svc.ListWithdrawals(ctx, domain.TransactionStatusPendingApproval, 50, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, wd.Transaction.ID, queue[0].ID)
+
+	_, err = f.svc.ReviewWithdrawal(ctx, wd.Transaction.ID, admin, false, " ")
+	assert.ErrorIs(t, err, ErrInvalidWithdrawal, "rejecting needs a reason")
+	tx, err := f.svc.ReviewWithdrawal(ctx, wd.Transaction.ID, admin, true, "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusProcessing, tx.Status)
+	assert.Equal(t, admin.String(), tx.Metadata[ReviewedByKey])
+	_, err = f.svc.ReviewWithdrawal(ctx, wd.Transaction.ID, admin, true, "")
+	assert.ErrorIs(t, err, ErrWithdrawalNotReviewed)
+
+	wd, err = f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+	require.NoError(t, err)
+	tx, err = f.svc.ReviewWithdrawal(ctx, wd.Transaction.ID, admin, false, "destination does not match KYC name")
+	require.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusFailed, tx.Status)
+	assert.True(t, f.wallet.AvailableBalance.Equal(decimal.NewFromInt(350000)), "only the approved withdrawal stays reserved")
+}
+
+func TestReleaseWithdrawal(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	f.wallet.AvailableBalance = decimal.NewFromInt(500000)
+	req := WithdrawRequest{Amount: decimal.NewFromInt(150000), Currency: domain.MWK, Destination: mobileMoney}
+
+	wd, err := f.svc.Withdraw(ctx, f.wallet.ID, f.owner.ID, req)
+	require.NoError(t, err)
+	released, err := f.svc.ReleaseWithdrawal(ctx, wd.Transaction, "never sent")
+	require.NoError(t, err)
+	assert.False(t, released, "only withdrawals still pending are released")
+
+	// As if the process stopped between reserving and dispatching.
+	tx := f.txs.byRef[wd.Transaction.Reference]
+	tx.Status = domain.TransactionStatusPending
+	released, err = f.svc.ReleaseWithdrawal(ctx, tx, "never sent")
+	require.NoError(t, err)
+	assert.True(t, released)
+	assert.Equal(t, domain.TransactionStatusFailed, tx.Status)
+	assert.True(t, f.wallet.AvailableBalance.Equal(decimal.NewFromInt(500000)))
+	assert.True(t, f.wallet.ReservedBalance.IsZero())
+}
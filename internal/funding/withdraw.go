@@ -0,0 +1,591 @@
+package funding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidWithdrawal     = errors.New("invalid withdrawal")
+	ErrInsufficientFunds     = errors.New("insufficient available balance")
+	ErrWithdrawalNotFound    = errors.New("withdrawal not found")
+	ErrWithdrawalNotReviewed = errors.New("withdrawal is not awaiting approval")
+	// ErrWithdrawalRefused is returned for a withdrawal the owner's
+	// blocklisting, spending controls or the daily limit forbid.
+	ErrWithdrawalRefused = errors.New("withdrawal refused")
+)
+
+// Metadata keys set on withdrawal transactions.
+const (
+	DestinationKey = "payout_destination"
+	ReviewedByKey  = "reviewed_by"
+	ReviewedAtKey  = "reviewed_at"
+)
+
+// Disbursement asks a payout provider to pay a withdrawal out.
+type Disbursement struct {
+	Reference   string
+	Amount      decimal.Decimal
+	Currency    domain.Currency
+	Destination domain.PayoutDestination
+	Narration   string
+}
+
+// PayoutResult is a provider's answer to a disbursement. Providers that
+// pay out at once report CallbackSucceeded; most report CallbackPending
+// and send the outcome in a callback.
+type PayoutResult struct {
+	ProviderReference string
+	Status            CallbackStatus
+	Reason            string
+}
+
+// PayoutProvider pays withdrawals out through an external payment network.
+type PayoutProvider interface {
+	Name() string
+	Destinations() []domain.PayoutDestinationType
+	Payout(ctx context.Context, d *Disbursement) (*PayoutResult, error)
+	// ParsePayoutCallback authenticates a payout callback, returning
+	// ErrInvalidCallback for anything not from the provider.
+	ParsePayoutCallback(ctx context.Context, header http.Header, body []byte) (*CallbackResult, error)
+}
+
+// WithPayoutProvider adds a payout provider. Withdrawals go to the first
+// one added that pays to their destination type unless the customer names
+// one.
+func (s *Service) WithPayoutProvider(p PayoutProvider) *Service {
+	s.payouts = append(s.payouts, p)
+	return s
+}
+
+// WithApprovalThreshold holds withdrawals above amount for an admin to
+// approve before they are paid out. Zero approves every withdrawal
+// automatically.
+func (s *Service) WithApprovalThreshold(amount decimal.Decimal) *Service {
+	s.approvalThreshold = amount
+	return s
+}
+
+// FreezeChecker refuses money movements an admin has frozen with
+// kyd/pkg/errors.ErrMovementFrozen. Satisfied by freeze.Service.
+type FreezeChecker interface {
+	Check(ctx context.Context, m domain.Movement) error
+}
+
+// WithFreezes refuses withdrawals, and the approval of held ones, while a
+// money movement freeze covers them.
+func (s *Service) WithFreezes(f FreezeChecker) *Service {
+	s.freezes = f
+	return s
+}
+
+func (s *Service) checkFreeze(ctx context.Context, currency domain.Currency) error {
+	if s.freezes == nil {
+		return nil
+	}
+	return s.freezes.Check(ctx, domain.Movement{Kind: domain.MovementWithdrawal, From: currency, To: currency})
+}
+
+// Blocklist reports blocklisted users; satisfied by the postgres security
+// repository.
+type Blocklist interface {
+	IsBlacklisted(ctx context.Context, value string) (bool, error)
+}
+
+// SpendingControlRepository reads the limits customers set on their own
+// outgoing money; satisfied by the postgres spending control repository.
+type SpendingControlRepository interface {
+	// Get returns the user's controls, or nil when they have set none.
+	Get(ctx context.Context, userID uuid.UUID) (*domain.SpendingControls, error)
+}
+
+// DailyLimit is the platform limit on what a user sends in 24 hours;
+// satisfied by risk.RiskEngine.
+type DailyLimit interface {
+	CheckDailyLimit(amount, dailyTotal decimal.Decimal) error
+}
+
+// WithScreening refuses withdrawals by blocklisted users and those over the
+// owner's own spending controls or the daily limit, which they share with
+// payments.
+func (s *Service) WithScreening(b Blocklist, controls SpendingControlRepository, limit DailyLimit) *Service {
+	s.blocklist, s.controls, s.dailyLimit = b, controls, limit
+	return s
+}
+
+// screenWithdrawal runs the checks WithScreening configures. Each lookup
+// fails closed, as for payments.
+func (s *Service) screenWithdrawal(ctx context.Context, userID uuid.UUID, amount decimal.Decimal, currency domain.Currency) error {
+	if s.blocklist != nil {
+		blocked, err := s.blocklist.IsBlacklisted(ctx, userID.String())
+		if err != nil {
+			return fmt.Errorf("check blocklist: %w", err)
+		}
+		if blocked {
+			s.logger.Warn("Withdrawal blocked: owner is blacklisted", map[string]interface{}{"user_id": userID})
+			return fmt.Errorf("%w: account is restricted", ErrWithdrawalRefused)
+		}
+	}
+	if s.controls == nil && s.dailyLimit == nil {
+		return nil
+	}
+	// Withdrawals count towards the daily total like payments do.
+	dailyTotal, err := s.txs.GetDailyTotal(ctx, userID, currency)
+	if err != nil {
+		return fmt.Errorf("get daily total: %w", err)
+	}
+	if s.controls != nil {
+		c, err := s.controls.Get(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("load spending controls: %w", err)
+		}
+		if err := checkSpendingControls(c, amount, dailyTotal); err != nil {
+			return err
+		}
+	}
+	if s.dailyLimit != nil {
+		if err := s.dailyLimit.CheckDailyLimit(amount, dailyTotal); err != nil {
+			return fmt.Errorf("%w: %w", ErrWithdrawalRefused, err)
+		}
+	}
+	return nil
+}
+
+// checkSpendingControls applies the controls that limit any outgoing money:
+// the freeze, the per-transaction maximum and the daily cap.
+func checkSpendingControls(c *domain.SpendingControls, amount, dailyTotal decimal.Decimal) error {
+	switch {
+	case c == nil:
+		return nil
+	case c.Frozen:
+		return fmt.Errorf("%w: outgoing payments are frozen by your spending controls", ErrWithdrawalRefused)
+	case c.MaxPerTransaction != nil && amount.GreaterThan(*c.MaxPerTransaction):
+		return fmt.Errorf("%w: amount exceeds your per-transaction maximum of %s", ErrWithdrawalRefused, c.MaxPerTransaction)
+	case c.DailyCap != nil && dailyTotal.Add(amount).GreaterThan(*c.DailyCap):
+		return fmt.Errorf("%w: amount exceeds your daily cap of %s", ErrWithdrawalRefused, c.DailyCap)
+	}
+	return nil
+}
+
+// WithdrawRequest is a customer's request to cash out of a wallet.
+type WithdrawRequest struct {
+	Amount      decimal.Decimal          `json:"amount"`
+	Currency    domain.Currency          `json:"currency"`
+	Destination domain.PayoutDestination `json:"destination"`
+	// Provider picks a payout provider by name; empty picks the first
+	// that pays to the destination type.
+	Provider string `json:"provider,omitempty"`
+}
+
+// Withdrawal is a started cash-out.
+type Withdrawal struct {
+	Transaction      *domain.Transaction `json:"transaction"`
+	Provider         string              `json:"provider"`
+	RequiresApproval bool                `json:"requires_approval"`
+}
+
+var (
+	bankCodePattern      = regexp.MustCompile(`^[A-Za-z0-9]{2,20}$`)
+	accountNumberPattern = regexp.MustCompile(`^[0-9A-Za-z]{5,34}$`)
+	phonePattern         = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+)
+
+func validDestination(d *domain.PayoutDestination) error {
+	d.AccountName = strings.TrimSpace(d.AccountName)
+	d.BankCode = strings.TrimSpace(d.BankCode)
+	d.AccountNumber = strings.ReplaceAll(strings.TrimSpace(d.AccountNumber), " ", "")
+	d.Phone = strings.ReplaceAll(strings.TrimSpace(d.Phone), " ", "")
+	if d.AccountName == "" {
+		return fmt.Errorf("%w: destination account_name is required", ErrInvalidWithdrawal)
+	}
+	switch d.Type {
+	case domain.PayoutBankAccount:
+		if !bankCodePattern.MatchString(d.BankCode) || !accountNumberPattern.MatchString(d.AccountNumber) {
+			return fmt.Errorf("%w: a bank account needs a bank_code and account_number", ErrInvalidWithdrawal)
+		}
+		d.Phone = ""
+	case domain.PayoutMobileMoney:
+		if !phonePattern.MatchString(d.Phone) {
+			return fmt.Errorf("%w: mobile money needs a phone number", ErrInvalidWithdrawal)
+		}
+		d.BankCode, d.AccountNumber = "", ""
+	default:
+		return fmt.Errorf("%w: destination type must be bank_account or mobile_money", ErrInvalidWithdrawal)
+	}
+	return nil
+}
+
+func (s *Service) payoutProvider(name string, dest domain.PayoutDestinationType) PayoutProvider {
+	for _, p := range s.payouts {
+		if name != "" && p.Name() != name {
+			continue
+		}
+		for _, d := range p.Destinations() {
+			if d == dest {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// Withdraw reserves the amount in walletID and pays it out to the
+// destination, or holds it for approval when it is above the threshold.
+// Only the wallet's owner may withdraw. The wallet is debited when the
+// provider confirms the payout; a failed payout returns the reservation.
+func (s *Service) Withdraw(ctx context.Context, walletID, userID uuid.UUID, req WithdrawRequest) (*Withdrawal, error) {
+	if !req.Amount.IsPositive() || req.Amount.Exponent() < -2 {
+		return nil, fmt.Errorf("%w: amount must be positive with at most two decimal places", ErrInvalidWithdrawal)
+	}
+	if err := validDestination(&req.Destination); err != nil {
+		return nil, err
+	}
+	w, err := s.wallets.FindByID(ctx, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+	if w.UserID != userID {
+		return nil, ErrNotWalletOwner
+	}
+	if w.Currency != req.Currency {
+		return nil, fmt.Errorf("%w: wallet holds %s", ErrInvalidWithdrawal, w.Currency)
+	}
+	if w.Status != domain.WalletStatusActive {
+		return nil, fmt.Errorf("%w: wallet is %s", ErrInvalidWithdrawal, w.Status)
+	}
+	if err := s.checkFreeze(ctx, w.Currency); err != nil {
+		return nil, err
+	}
+	owner, err := s.users.FindByID(ctx, w.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("find wallet owner: %w", err)
+	}
+	if owner.KYCStatus != domain.KYCStatusVerified {
+		return nil, fmt.Errorf("%w: wallet owner is not KYC verified", ErrInvalidWithdrawal)
+	}
+	if err := s.screenWithdrawal(ctx, w.UserID, req.Amount, req.Currency); err != nil {
+		return nil, err
+	}
+	p := s.payoutProvider(req.Provider, req.Destination.Type)
+	if p == nil {
+		if req.Provider != "" {
+			return nil, fmt.Errorf("%w: %s does not pay to %s", ErrNoProvider, req.Provider, req.Destination.Type)
+		}
+		return nil, ErrNoProvider
+	}
+	if w.AvailableBalance.LessThan(req.Amount) {
+		return nil, ErrInsufficientFunds
+	}
+
+	approval := s.approvalThreshold.IsPositive() && req.Amount.GreaterThan(s.approvalThreshold)
+	status := domain.TransactionStatusPending
+	if approval {
+		status = domain.TransactionStatusPendingApproval
+	}
+	now := time.Now()
+	id := uuid.New()
+	tx := &domain.Transaction{
+		ID: id,
+		// Providers echo the reference in their callbacks.
+		Reference:         fmt.Sprintf("WDR-%s", id),
+		SenderID:          w.UserID,
+		ReceiverID:        w.UserID,
+		SenderWalletID:    &w.ID,
+		ReceiverWalletID:  &w.ID,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		ExchangeRate:      decimal.NewFromInt(1),
+		ConvertedAmount:   req.Amount,
+		ConvertedCurrency: req.Currency,
+		NetAmount:         req.Amount,
+		Status:            status,
+		TransactionType:   domain.TransactionTypeWithdrawal,
+		Channel:           "api",
+		Category:          "cash_out",
+		Description:       fmt.Sprintf("Withdrawal to %s via %s", req.Destination.Type, p.Name()),
+		Metadata:          domain.Metadata{ProviderKey: p.Name(), DestinationKey: req.Destination},
+		InitiatedAt:       now,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if err := s.txs.Create(ctx, tx); err != nil {
+		return nil, err
+	}
+	if err := s.wallets.ReserveFunds(ctx, w.ID, req.Amount); err != nil {
+		tx.Status, tx.StatusReason, tx.UpdatedAt = domain.TransactionStatusFailed, "insufficient available balance", time.Now()
+		if _, terr := s.txs.TransitionStatus(ctx, tx, status); terr != nil {
+			s.logger.Error("Failed to fail withdrawal", map[string]interface{}{"transaction_id": tx.ID, "error": terr.Error()})
+		}
+		if errors.Is(err, pkgerrors.ErrInsufficientBalance) {
+			return nil, ErrInsufficientFunds
+		}
+		return nil, err
+	}
+
+	if approval {
+		s.logger.Info("Withdrawal held for approval", map[string]interface{}{
+			"transaction_id": tx.ID, "amount": tx.Amount, "currency": tx.Currency,
+		})
+		return &Withdrawal{Transaction: tx, Provider: p.Name(), RequiresApproval: true}, nil
+	}
+	if err := s.dispatch(ctx, p, tx); err != nil {
+		return nil, err
+	}
+	return &Withdrawal{Transaction: tx, Provider: p.Name()}, nil
+}
+
+// dispatch hands a reserved, pending withdrawal to its payout provider.
+func (s *Service) dispatch(ctx context.Context, p PayoutProvider, tx *domain.Transaction) error {
+	tx.Status, tx.UpdatedAt = domain.TransactionStatusProcessing, time.Now()
+	moved, err := s.txs.TransitionStatus(ctx, tx, domain.TransactionStatusPending)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		// Another request dispatched it first.
+		return nil
+	}
+
+	var dest domain.PayoutDestination
+	if err := decodeMetadata(tx.Metadata[DestinationKey], &dest); err != nil {
+		return fmt.Errorf("withdrawal %s destination: %w", tx.ID, err)
+	}
+	res, err := p.Payout(ctx, &Disbursement{
+		Reference:   tx.Reference,
+		Amount:      tx.Amount,
+		Currency:    tx.Currency,
+		Destination: dest,
+		Narration:   tx.Reference,
+	})
+	if err != nil {
+		s.logger.Error("Payout provider rejected withdrawal", map[string]interface{}{
+			"provider": p.Name(), "transaction_id": tx.ID, "error": err.Error(),
+		})
+		if _, err := s.cancel(ctx, tx, "payout provider rejected the withdrawal", nil); err != nil {
+			s.logger.Error("Failed to cancel withdrawal", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+		}
+		return ErrProviderUnavailable
+	}
+	metadata := domain.Metadata{}
+	if res.ProviderReference != "" {
+		metadata[ProviderReferenceKey] = res.ProviderReference
+	}
+	return s.settleWithdrawal(ctx, tx, res.Status, res.Reason, metadata)
+}
+
+// settleWithdrawal applies a payout outcome to a processing withdrawal.
+func (s *Service) settleWithdrawal(ctx context.Context, tx *domain.Transaction, status CallbackStatus, reason string, metadata domain.Metadata) error {
+	switch status {
+	case CallbackPending:
+		if len(metadata) == 0 {
+			return nil
+		}
+		for k, v := range metadata {
+			tx.Metadata[k] = v
+		}
+		return s.txs.SetMetadata(ctx, tx.ID, tx.Metadata)
+	case CallbackFailed:
+		if reason == "" {
+			reason = "payout not completed"
+		}
+		_, err := s.cancel(ctx, tx, reason, metadata)
+		return err
+	case CallbackSucceeded:
+	default:
+		return fmt.Errorf("%w: status %q", ErrInvalidCallback, status)
+	}
+
+	debited, err := s.ledger.PostWithdrawal(ctx, &ledger.FundingPosting{
+		TransactionID: tx.ID,
+		WalletID:      *tx.SenderWalletID,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		Metadata:      metadata,
+	})
+	if err != nil {
+		return err
+	}
+	if debited {
+		tx.Status = domain.TransactionStatusCompleted
+		s.logger.Info("Withdrawal paid out", map[string]interface{}{
+			"transaction_id": tx.ID, "wallet_id": *tx.SenderWalletID, "amount": tx.Amount, "currency": tx.Currency,
+		})
+	}
+	return nil
+}
+
+// cancel fails a withdrawal and returns its reservation. It reports false
+// when the withdrawal had already completed or failed.
+func (s *Service) cancel(ctx context.Context, tx *domain.Transaction, reason string, metadata domain.Metadata) (bool, error) {
+	cancelled, err := s.ledger.CancelWithdrawal(ctx, &ledger.FundingPosting{
+		TransactionID: tx.ID,
+		WalletID:      *tx.SenderWalletID,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+		Metadata:      metadata,
+	}, reason)
+	if err != nil {
+		return false, err
+	}
+	if cancelled {
+		tx.Status, tx.StatusReason = domain.TransactionStatusFailed, reason
+		s.logger.Info("Withdrawal cancelled", map[string]interface{}{"transaction_id": tx.ID, "reason": reason})
+	}
+	return cancelled, nil
+}
+
+// ReleaseWithdrawal fails a withdrawal that was reserved but never sent to
+// its payout provider, returning the reservation. It reports false when the
+// withdrawal has since been sent or has finished.
+func (s *Service) ReleaseWithdrawal(ctx context.Context, tx *domain.Transaction, reason string) (bool, error) {
+	if tx.TransactionType != domain.TransactionTypeWithdrawal || tx.SenderWalletID == nil {
+		return false, ErrWithdrawalNotFound
+	}
+	released, err := s.ledger.ReleaseWithdrawal(ctx, &ledger.FundingPosting{
+		TransactionID: tx.ID,
+		WalletID:      *tx.SenderWalletID,
+		Amount:        tx.Amount,
+		Currency:      tx.Currency,
+	}, reason)
+	if err != nil {
+		return false, err
+	}
+	if released {
+		tx.Status, tx.StatusReason = domain.TransactionStatusFailed, reason
+		s.logger.Info("Withdrawal reservation released", map[string]interface{}{"transaction_id": tx.ID, "reason": reason})
+	}
+	return released, nil
+}
+
+// decodeMetadata reads a metadata value back into v, whether it is still
+// the value stored or the JSON map it was loaded as.
+func decodeMetadata(value interface{}, v interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// HandlePayoutCallback applies a payout callback from the named provider.
+// Like deposit callbacks, repeats are acknowledged without effect.
+func (s *Service) HandlePayoutCallback(ctx context.Context, providerName string, header http.Header, body []byte) error {
+	var p PayoutProvider
+	for _, candidate := range s.payouts {
+		if candidate.Name() == providerName {
+			p = candidate
+		}
+	}
+	if p == nil {
+		return ErrUnknownProvider
+	}
+	res, err := p.ParsePayoutCallback(ctx, header, body)
+	if err != nil {
+		return err
+	}
+	tx, err := s.txs.FindByReference(ctx, res.Reference)
+	if err != nil || tx.TransactionType != domain.TransactionTypeWithdrawal || tx.Metadata[ProviderKey] != p.Name() {
+		return ErrWithdrawalNotFound
+	}
+	if tx.Status != domain.TransactionStatusProcessing {
+		return nil
+	}
+	if res.Status == CallbackSucceeded && (!res.Amount.Equal(tx.Amount) || res.Currency != tx.Currency) {
+		s.logger.Error("Payout callback amount mismatch", map[string]interface{}{
+			"transaction_id": tx.ID,
+			"expected":       tx.Amount.String() + " " + string(tx.Currency),
+			"received":       res.Amount.String() + " " + string(res.Currency),
+		})
+		return fmt.Errorf("%w: paid out %s %s for a withdrawal of %s %s", ErrInvalidCallback, res.Amount, res.Currency, tx.Amount, tx.Currency)
+	}
+	metadata := domain.Metadata{}
+	if res.ProviderReference != "" {
+		metadata[ProviderReferenceKey] = res.ProviderReference
+	}
+	return s.settleWithdrawal(ctx, tx, res.Status, res.Reason, metadata)
+}
+
+// ListWithdrawals returns withdrawals in status, newest first; an empty
+// status lists them all.
+func (s *Service) ListWithdrawals(ctx context.Context, status domain.TransactionStatus, limit, offset int) ([]*domain.Transaction, int, error) {
+	return s.txs.FindByType(ctx, domain.TransactionTypeWithdrawal, status, limit, offset)
+}
+
+// ReviewWithdrawal approves a withdrawal held for approval, paying it out,
+// or rejects it and returns the reservation. The reviewer is recorded on
+// the transaction.
+func (s *Service) ReviewWithdrawal(ctx context.Context, id, adminID uuid.UUID, approve bool, reason string) (*domain.Transaction, error) {
+	tx, err := s.txs.FindByID(ctx, id)
+	if err != nil || tx.TransactionType != domain.TransactionTypeWithdrawal {
+		return nil, ErrWithdrawalNotFound
+	}
+	if tx.Status != domain.TransactionStatusPendingApproval {
+		return nil, ErrWithdrawalNotReviewed
+	}
+	reason = strings.TrimSpace(reason)
+	review := domain.Metadata{ReviewedByKey: adminID.String(), ReviewedAtKey: time.Now().UTC()}
+
+	if !approve {
+		if reason == "" {
+			return nil, fmt.Errorf("%w: a reason is required to reject", ErrInvalidWithdrawal)
+		}
+		review["rejection_reason"] = reason
+		cancelled, err := s.cancel(ctx, tx, "rejected: "+reason, review)
+		if err != nil {
+			return nil, err
+		}
+		if !cancelled {
+			return nil, ErrWithdrawalNotReviewed
+		}
+		for k, v := range review {
+			tx.Metadata[k] = v
+		}
+		return tx, nil
+	}
+
+	if err := s.checkFreeze(ctx, tx.Currency); err != nil {
+		return nil, err
+	}
+	name, _ := tx.Metadata[ProviderKey].(string)
+	var p PayoutProvider
+	for _, candidate := range s.payouts {
+		if candidate.Name() == name {
+			p = candidate
+		}
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%w: %s is no longer configured", ErrNoProvider, name)
+	}
+	tx.Status, tx.UpdatedAt = domain.TransactionStatusPending, time.Now()
+	moved, err := s.txs.TransitionStatus(ctx, tx, domain.TransactionStatusPendingApproval)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return nil, ErrWithdrawalNotReviewed
+	}
+	for k, v := range review {
+		tx.Metadata[k] = v
+	}
+	if err := s.txs.SetMetadata(ctx, tx.ID, tx.Metadata); err != nil {
+		s.logger.Error("Failed to record withdrawal reviewer", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+	}
+	s.logger.Info("Withdrawal approved", map[string]interface{}{"transaction_id": tx.ID, "admin_id": adminID})
+	if err := s.dispatch(ctx, p, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
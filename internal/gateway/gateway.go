@@ -0,0 +1,667 @@
+// Package gateway is the public edge of the platform: security headers,
+// CORS, CSRF, request signing and admin checks, then routing by path prefix
+// to the service that owns it. Backends are reverse proxies to the
+// individual services in microservice mode and the services' own routers in
+// monolith mode.
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kyd/internal/middleware"
+	"kyd/pkg/config"
+	"kyd/pkg/errors"
+	"kyd/pkg/logger"
+	"kyd/pkg/tracing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type Gateway struct {
+	backends       Backends
+	logger         logger.Logger
+	redisClient    *redis.Client
+	rateLimiter    *middleware.RateLimiter
+	jwtSecret      string
+	signingSecret  string
+	requireSigning bool
+	signatureTTL   time.Duration
+}
+
+// Backends are the handlers the gateway routes to, one per service.
+type Backends struct {
+	Auth       http.Handler
+	Payment    http.Handler
+	Wallet     http.Handler
+	Forex      http.Handler
+	Settlement http.Handler
+}
+
+func isAdminToken(tokenStr string, secret string) bool {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return false
+	}
+	if !token.Valid {
+		return false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	ut, _ := claims["user_type"].(string)
+	return ut == "admin"
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func envBool(key string, def bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "1", "true", "yes", "y", "on":
+		return true
+	case "0", "false", "no", "n", "off":
+		return false
+	default:
+		return def
+	}
+}
+
+func NewGateway(log logger.Logger, redisClient *redis.Client, cfg *config.Config, backends Backends) *Gateway {
+	env := strings.ToLower(strings.TrimSpace(os.Getenv("ENV")))
+	if env == "" {
+		env = "local"
+	}
+	enableRateLimiter := envBool("GATEWAY_RATE_LIMIT_ENABLED", env != "local")
+	var rl *middleware.RateLimiter
+	if enableRateLimiter {
+		rl = middleware.NewRateLimiter(redisClient, 100, time.Minute).WithName("gateway").WithAdaptive(10, 30*time.Minute).
+			WithFailureMode(middleware.FailureMode(cfg.Redis.RateLimitFailure))
+	}
+
+	return &Gateway{
+		backends:       backends,
+		logger:         log,
+		redisClient:    redisClient,
+		rateLimiter:    rl,
+		jwtSecret:      cfg.JWT.Secret,
+		signingSecret:  cfg.Security.SigningSecret,
+		requireSigning: cfg.Security.RequireSigning,
+		signatureTTL:   cfg.Security.SignatureTTL,
+	}
+}
+
+// ProxyBackends reverse-proxies every service to its *_SERVICE_URL. With
+// SERVER_USE_TLS set the gateway presents its certificate to the services
+// and verifies theirs against the configured CA.
+func ProxyBackends(cfg *config.Config) (Backends, error) {
+	tlsConfig, err := proxyTLS(cfg.Server)
+	if err != nil {
+		return Backends{}, err
+	}
+	return Backends{
+		Auth:       createReverseProxy(getEnv("AUTH_SERVICE_URL", "http://127.0.0.1:3000"), tlsConfig),
+		Payment:    createReverseProxy(getEnv("PAYMENT_SERVICE_URL", "http://127.0.0.1:3001"), tlsConfig),
+		Wallet:     createReverseProxy(getEnv("WALLET_SERVICE_URL", "http://127.0.0.1:3003"), tlsConfig),
+		Forex:      createReverseProxy(getEnv("FOREX_SERVICE_URL", "http://127.0.0.1:3002"), tlsConfig),
+		Settlement: createReverseProxy(getEnv("SETTLEMENT_SERVICE_URL", "http://127.0.0.1:3004"), tlsConfig),
+	}, nil
+}
+
+func proxyTLS(cfg config.ServerConfig) (*tls.Config, error) {
+	if !cfg.UseTLS {
+		return nil, nil
+	}
+	// Load Client Cert for mTLS
+	keyPair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load client keypair")
+	}
+
+	// Load CA to verify backend server
+	caCert, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CA file")
+	}
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("CA file contains no certificates")
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		RootCAs:      caCertPool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func createReverseProxy(target string, tlsConfig *tls.Config) *httputil.ReverseProxy {
+	url, _ := url.Parse(target)
+	proxy := httputil.NewSingleHostReverseProxy(url)
+
+	var transport http.RoundTripper
+	if tlsConfig != nil {
+		transport = &http.Transport{
+			TLSClientConfig: tlsConfig,
+		}
+	}
+	// Each proxied call gets a client span and forwards the traceparent, so
+	// the backend's spans join the gateway's trace.
+	proxy.Transport = tracing.Transport(transport)
+
+	// Store original Director to capture request
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		if originalDirector != nil {
+			originalDirector(req)
+		}
+		// Store origin header in a custom header that gets forwarded to backend
+		// This allows ModifyResponse to access it even if backend modifies headers
+		if origin := req.Header.Get("Origin"); origin != "" {
+			req.Header.Set("X-Gateway-Origin", origin)
+		}
+		// Inject Idempotency-Key for unsafe methods if missing
+		if req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch || req.Method == http.MethodDelete {
+			if req.Header.Get("Idempotency-Key") == "" {
+				req.Header.Set("Idempotency-Key", uuid.NewString())
+			}
+		}
+	}
+
+	// Handle proxy errors by adding CORS headers
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		origin := r.Header.Get("Origin")
+		allowedOrigins := getAllowedOrigins()
+
+		allowed := isOriginAllowed(origin, allowedOrigins)
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+		// Return a JSON error to be more API friendly
+		w.Write([]byte(fmt.Sprintf(`{"error": "Bad Gateway", "message": "%v"}`, err)))
+	}
+
+	// Modify response to handle CORS properly
+	originalModifyResponse := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		// Get origin from request (stored in custom header by Director)
+		origin := resp.Request.Header.Get("X-Gateway-Origin")
+		if origin == "" {
+			origin = resp.Request.Header.Get("Origin")
+		}
+		replaceCORSHeaders(resp.Header, origin)
+
+		// Call original ModifyResponse if it exists
+		if originalModifyResponse != nil {
+			return originalModifyResponse(resp)
+		}
+		return nil
+	}
+
+	return proxy
+}
+
+// LocalBackend serves a service's router in-process, as monolith mode does,
+// with the same request and response treatment the reverse proxies give it:
+// unsafe methods get an Idempotency-Key when the client sent none, and the
+// service's CORS headers are replaced by the gateway's.
+func LocalBackend(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete {
+			if r.Header.Get("Idempotency-Key") == "" {
+				r.Header.Set("Idempotency-Key", uuid.NewString())
+			}
+		}
+		h.ServeHTTP(&corsRewriter{ResponseWriter: w, origin: r.Header.Get("Origin")}, r)
+	})
+}
+
+// corsRewriter applies replaceCORSHeaders just before the service's headers
+// are written.
+type corsRewriter struct {
+	http.ResponseWriter
+	origin      string
+	wroteHeader bool
+}
+
+func (c *corsRewriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		replaceCORSHeaders(c.ResponseWriter.Header(), c.origin)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *corsRewriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working through the wrapper.
+func (c *corsRewriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the forex WebSocket upgrade take over the connection.
+func (c *corsRewriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hj.Hijack()
+}
+
+func (c *corsRewriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// replaceCORSHeaders drops the CORS headers a service set and applies the
+// gateway's, so browsers see one consistent policy whichever service answered.
+func replaceCORSHeaders(h http.Header, origin string) {
+	// Remove CORS headers that backend services might set
+	h.Del("Access-Control-Allow-Origin")
+	h.Del("Access-Control-Allow-Methods")
+	h.Del("Access-Control-Allow-Headers")
+	h.Del("Access-Control-Allow-Credentials")
+	h.Del("Access-Control-Max-Age")
+
+	allowedOrigins := getAllowedOrigins()
+
+	allowed := isOriginAllowed(origin, allowedOrigins)
+
+	// Set CORS headers
+	if allowed {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+	} else if origin != "" {
+		h.Set("Access-Control-Allow-Origin", "*")
+	}
+
+	h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	h.Set("Access-Control-Allow-Headers", "Content-Type, Accept, Authorization, Idempotency-Key, X-Request-ID, X-CSRF-Token, X-Signature, X-Signature-Timestamp, X-API-Key")
+	h.Set("Access-Control-Max-Age", "3600")
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Security headers
+	secureHeaders(w)
+	// Apply CORS headers early so browser sees them even on error paths
+	applyCORSHeaders(w, r)
+
+	// Issue CSRF cookie for safe methods
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if c, err := r.Cookie("csrf_token"); err != nil || c.Value == "" {
+			token := uuid.NewString()
+			http.SetCookie(w, &http.Cookie{
+				Name:     "csrf_token",
+				Value:    token,
+				Path:     "/",
+				HttpOnly: false, // double-submit cookie
+				SameSite: http.SameSiteLaxMode,
+				Secure:   false,
+				MaxAge:   3600,
+			})
+		}
+	}
+
+	// Ensure request ID
+	if r.Header.Get("X-Request-ID") == "" {
+		rid := uuid.NewString()
+		r.Header.Set("X-Request-ID", rid)
+		w.Header().Set("X-Request-ID", rid)
+	}
+
+	// Enforce JSON on mutating methods
+	if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		ct := r.Header.Get("Content-Type")
+		if ct == "" || (ct != "application/json" && ct != "application/json; charset=utf-8") {
+			// Exempt endpoints that require multipart/form-data (e.g. KYC file upload)
+			if !isUploadPath(r.URL.Path) {
+				applyCORSHeaders(w, r)
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				w.Write([]byte(`{"error":"unsupported_media_type","message":"Content-Type must be application/json"}`))
+				return
+			}
+		}
+		// CSRF check (double-submit cookie), exempt login/register and
+		// API key calls, which carry no cookies
+		path := r.URL.Path
+		if r.Header.Get("X-API-Key") == "" && !(matchPath(path, "/api/v1/auth/login") ||
+			matchPath(path, "/api/v1/auth/register") ||
+			matchPath(path, "/api/v1/auth/verify") ||
+			matchPath(path, "/api/v1/auth/verify/resend") ||
+			matchPath(path, "/api/v1/auth/google/callback") ||
+			matchPath(path, "/api/v1/auth/forgot-password") ||
+			matchPath(path, "/api/v1/auth/reset-password") ||
+			matchPath(path, "/api/v1/auth/invitations") ||
+			matchPath(path, "/api/v1/auth/eligibility/appeals") ||
+			matchPath(path, "/api/v1/support/lookup") ||
+			matchPath(path, "/api/v1/forex")) {
+			csrfCookie, _ := r.Cookie("csrf_token")
+			csrfHeader := r.Header.Get("X-CSRF-Token")
+			if csrfCookie == nil || csrfCookie.Value == "" || csrfHeader == "" || csrfCookie.Value != csrfHeader {
+				applyCORSHeaders(w, r)
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error":"csrf_failed","message":"Invalid CSRF token"}`))
+				return
+			}
+		}
+	}
+
+	// Limit body size to 1MB for safety
+	const maxBody = int64(1 << 20) // 1MB
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+	if g.requireSigning && r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+		if requiresGatewaySigning(r.URL.Path) {
+			bodyBytes, err := io.ReadAll(r.Body)
+			if err != nil {
+				applyCORSHeaders(w, r)
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_body","message":"Unable to read request body"}`))
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			if !g.verifySignature(r, bodyBytes) {
+				applyCORSHeaders(w, r)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_signature","message":"Invalid or missing request signature"}`))
+				return
+			}
+		}
+	}
+
+	// Define downstream handler with routing logic
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Handle OPTIONS preflight requests
+		if r.Method == "OPTIONS" {
+			g.handleCORS(w, r)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Log request basic info
+		g.logger.Info("Gateway request", map[string]interface{}{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"ip":     r.RemoteAddr,
+			"rid":    r.Header.Get("X-Request-ID"),
+		})
+
+		// Add common headers
+		w.Header().Set("X-Gateway-Version", "1.0.0")
+		w.Header().Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+
+		// If Authorization missing, inject from secure cookie access_token
+		if r.Header.Get("Authorization") == "" {
+			if c, err := r.Cookie("access_token"); err == nil && c.Value != "" {
+				r.Header.Set("Authorization", "Bearer "+c.Value)
+			}
+		}
+
+		// RBAC: admin routes must have user_type=admin in JWT
+		if matchPath(r.URL.Path, "/api/v1/admin") {
+			authz := r.Header.Get("Authorization")
+			if authz == "" {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			// parse "Bearer xxx"
+			// simple split
+			tokenStr := authz
+			if len(tokenStr) > 7 && tokenStr[:7] == "Bearer " {
+				tokenStr = tokenStr[7:]
+			}
+			// Validate token and claims
+			if !isAdminToken(tokenStr, g.jwtSecret) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		// Route to appropriate service (CORS handled in ModifyResponse)
+		switch {
+		case matchPath(r.URL.Path, "/api/v1/auth"):
+			g.backends.Auth.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/notifications"):
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/exports"), matchPath(r.URL.Path, "/api/v1/kyc"):
+			// Signed export and KYC document downloads are served by the
+			// payment service
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/admin"):
+			// Admin endpoints are handled by payment service
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/payments"):
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/compliance"):
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/home"):
+			// The home screen aggregates wallets, payments and rates
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/limits"):
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/dormancy"):
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/data-sharing"), matchPath(r.URL.Path, "/api/v1/partners"):
+			// Users' consents and the credit-scoring partners reading them
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/credit-facilities"):
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/standing-instructions"), matchPath(r.URL.Path, "/api/v1/pockets"):
+			// Standing instructions on incoming funds and their savings pockets
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/status"):
+			// The public status page is served by the payment service
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/developer"):
+			// Developer portal data is served by the payment service
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/support"):
+			// Call-centre lookups are answered by the payment service
+			g.backends.Payment.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/wallets"):
+			g.backends.Wallet.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/deposits"), matchPath(r.URL.Path, "/api/v1/withdrawals"):
+			// Payment provider callbacks completing wallet top-ups and payouts
+			g.backends.Wallet.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/forex"):
+			g.backends.Forex.ServeHTTP(w, r)
+		case matchPath(r.URL.Path, "/api/v1/settlements"):
+			g.backends.Settlement.ServeHTTP(w, r)
+		default:
+			http.Error(w, "Service not found", http.StatusNotFound)
+			return
+		}
+	})
+
+	if g.rateLimiter != nil {
+		g.rateLimiter.Limit(next).ServeHTTP(w, r)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+func requiresGatewaySigning(path string) bool {
+	if matchPath(path, "/api/v1/payments") {
+		return true
+	}
+	if matchPath(path, "/api/v1/wallets") {
+		return true
+	}
+	return false
+}
+
+func (g *Gateway) verifySignature(r *http.Request, body []byte) bool {
+	if g.signingSecret == "" {
+		return false
+	}
+	sig := r.Header.Get("X-Signature")
+	ts := r.Header.Get("X-Signature-Timestamp")
+	if sig == "" || ts == "" {
+		return false
+	}
+	tsInt, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	now := time.Now().Unix()
+	ttl := int64(g.signatureTTL.Seconds())
+	if ttl <= 0 {
+		ttl = int64((5 * time.Minute).Seconds())
+	}
+	if now-tsInt > ttl || tsInt-now > ttl {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(g.signingSecret))
+	mac.Write([]byte(r.Method))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(r.URL.Path))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	sigBytes, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, sigBytes)
+}
+
+func (g *Gateway) handleCORS(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	allowedOrigins := getAllowedOrigins()
+
+	allowed := isOriginAllowed(origin, allowedOrigins)
+
+	if allowed {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	} else if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept, Authorization, Idempotency-Key, X-Request-ID, X-CSRF-Token, X-Signature, X-Signature-Timestamp, X-API-Key")
+	w.Header().Set("Access-Control-Max-Age", "3600")
+}
+
+func secureHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'; base-uri 'self'; form-action 'self'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains; preload")
+	w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=(), payment=(), usb=()")
+	w.Header().Set("X-DNS-Prefetch-Control", "off")
+	w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
+}
+
+// isUploadPath reports whether path takes multipart/form-data uploads.
+func isUploadPath(path string) bool {
+	return matchPath(path, "/api/v1/compliance/kyc/submit") ||
+		path == "/api/v1/auth/imports" ||
+		(matchPath(path, "/api/v1/compliance/kyc/documents/") && strings.HasSuffix(path, "/reupload"))
+}
+
+func matchPath(path, prefix string) bool {
+	return len(path) >= len(prefix) && path[:len(prefix)] == prefix
+}
+
+func applyCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	allowedOrigins := getAllowedOrigins()
+	if isOriginAllowed(origin, allowedOrigins) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	} else if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept, Authorization, Idempotency-Key, X-Request-ID, X-CSRF-Token, X-Signature, X-Signature-Timestamp, X-API-Key")
+	w.Header().Set("Access-Control-Max-Age", "3600")
+}
+func getAllowedOrigins() []string {
+	if v, ok := os.LookupEnv("ALLOWED_ORIGINS"); ok && strings.TrimSpace(v) != "" {
+		parts := strings.Split(v, ",")
+		out := make([]string, 0, len(parts))
+		for _, p := range parts {
+			t := strings.TrimSpace(p)
+			if t != "" {
+				out = append(out, t)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return []string{
+		"http://localhost:3012",
+		"http://localhost:3016",
+		"http://127.0.0.1:3012",
+		"http://127.0.0.1:3016",
+	}
+}
+
+func isOriginAllowed(origin string, allowed []string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, o := range allowed {
+		if origin == o {
+			return true
+		}
+	}
+	// In local env, be permissive: allow any http/https origin
+	env := strings.ToLower(os.Getenv("ENV"))
+	if env == "" {
+		env = "local"
+	}
+	if env == "local" {
+		if strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://") {
+			return true
+		}
+	}
+	return false
+}
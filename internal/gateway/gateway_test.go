@@ -0,0 +1,99 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// named answers with its name so tests can tell which backend was reached.
+func named(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	})
+}
+
+func newTestGateway(t *testing.T, b Backends) *Gateway {
+	t.Setenv("ENV", "local")
+	return NewGateway(logger.NewNop(), nil, &config.Config{}, b)
+}
+
+func TestGatewayRoutesToBackends(t *testing.T) {
+	g := newTestGateway(t, Backends{
+		Auth:       named("auth"),
+		Payment:    named("payment"),
+		Wallet:     named("wallet"),
+		Forex:      named("forex"),
+		Settlement: named("settlement"),
+	})
+
+	cases := map[string]string{
+		"/api/v1/auth/me":               "auth",
+		"/api/v1/payments":              "payment",
+		"/api/v1/compliance/kyc":        "payment",
+		"/api/v1/notifications":         "payment",
+		"/api/v1/home":                  "payment",
+		"/api/v1/limits":                "payment",
+		"/api/v1/support/lookup":        "payment",
+		"/api/v1/kyc/documents":         "payment",
+		"/api/v1/wallets/abc":           "wallet",
+		"/api/v1/deposits/callbacks":    "wallet",
+		"/api/v1/withdrawals/callbacks": "wallet",
+		"/api/v1/forex/rates":           "forex",
+		"/api/v1/settlements/health":    "settlement",
+	}
+	for path, want := range cases {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, rec.Body.String(), path)
+	}
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestLocalBackendInjectsIdempotencyKey(t *testing.T) {
+	var got string
+	h := LocalBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Idempotency-Key")
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/payments", strings.NewReader("{}")))
+	assert.NotEmpty(t, got)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments", strings.NewReader("{}"))
+	req.Header.Set("Idempotency-Key", "client-key")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "client-key", got)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/payments", nil))
+	assert.Empty(t, got)
+}
+
+func TestLocalBackendReplacesServiceCORS(t *testing.T) {
+	t.Setenv("ALLOWED_ORIGINS", "https://app.example.com")
+	h := LocalBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// What middleware.CORS on a service router would set.
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key")
+}
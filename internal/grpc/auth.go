@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"context"
+
+	"kyd/internal/auth"
+	"kyd/internal/grpc/kydv1"
+
+	"google.golang.org/grpc/codes"
+)
+
+// TokenIntrospector checks access tokens; satisfied by auth.Service.
+type TokenIntrospector interface {
+	IntrospectToken(ctx context.Context, token string) (*auth.TokenInfo, error)
+}
+
+// AuthServer serves kyd.v1.AuthService.
+type AuthServer struct {
+	kydv1.UnimplementedAuthServiceServer
+	tokens TokenIntrospector
+}
+
+func NewAuthServer(tokens TokenIntrospector) *AuthServer {
+	return &AuthServer{tokens: tokens}
+}
+
+func (s *AuthServer) IntrospectToken(ctx context.Context, in *kydv1.IntrospectTokenRequest) (*kydv1.IntrospectTokenResponse, error) {
+	if in.Token == "" {
+		return &kydv1.IntrospectTokenResponse{}, nil
+	}
+	info, err := s.tokens.IntrospectToken(ctx, in.Token)
+	if err != nil {
+		// Like the auth middleware, fail closed when revocation cannot be
+		// checked, but let the caller retry.
+		return nil, statusError(err, codes.Unavailable)
+	}
+	if !info.Active {
+		return &kydv1.IntrospectTokenResponse{}, nil
+	}
+	return &kydv1.IntrospectTokenResponse{
+		Active:    true,
+		UserId:    info.UserID.String(),
+		Email:     info.Email,
+		UserType:  string(info.UserType),
+		AdminRole: info.AdminRole,
+		ExpiresAt: timestamp(info.ExpiresAt),
+	}, nil
+}
@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"kyd/internal/grpc/kydv1"
+	"kyd/pkg/config"
+
+	"google.golang.org/grpc"
+)
+
+// Clients are gRPC clients of the four services, at the addresses
+// config.GRPCConfig names. Connections are made on first call.
+type Clients struct {
+	Auth    kydv1.AuthServiceClient
+	Payment kydv1.PaymentServiceClient
+	Wallet  kydv1.WalletServiceClient
+	Forex   kydv1.ForexServiceClient
+
+	conns []*grpc.ClientConn
+}
+
+func NewClients(cfg *config.Config) (*Clients, error) {
+	c := &Clients{}
+	for _, addr := range []string{cfg.GRPC.AuthAddr, cfg.GRPC.PaymentAddr, cfg.GRPC.WalletAddr, cfg.GRPC.ForexAddr} {
+		conn, err := Dial(addr, cfg.Server)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+	}
+	c.Auth = kydv1.NewAuthServiceClient(c.conns[0])
+	c.Payment = kydv1.NewPaymentServiceClient(c.conns[1])
+	c.Wallet = kydv1.NewWalletServiceClient(c.conns[2])
+	c.Forex = kydv1.NewForexServiceClient(c.conns[3])
+	return c, nil
+}
+
+// Close closes every connection.
+func (c *Clients) Close() error {
+	var first error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	c.conns = nil
+	return first
+}
@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/forex"
+	"kyd/internal/grpc/kydv1"
+	"kyd/pkg/validator"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ForexService is the part of forex.Service the forex API serves.
+type ForexService interface {
+	GetRate(ctx context.Context, from, to domain.Currency) (*domain.ExchangeRate, error)
+	Calculate(ctx context.Context, req *forex.CalculateRequest) (*forex.CalculateResponse, error)
+}
+
+// ForexServer serves kyd.v1.ForexService.
+type ForexServer struct {
+	kydv1.UnimplementedForexServiceServer
+	service   ForexService
+	validator *validator.Validator
+}
+
+func NewForexServer(service ForexService, val *validator.Validator) *ForexServer {
+	return &ForexServer{service: service, validator: val}
+}
+
+func (s *ForexServer) GetRate(ctx context.Context, in *kydv1.GetRateRequest) (*kydv1.ExchangeRate, error) {
+	if in.From == "" || in.To == "" {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	rate, err := s.service.GetRate(ctx, domain.Currency(in.From), domain.Currency(in.To))
+	if err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	return &kydv1.ExchangeRate{
+		BaseCurrency:   string(rate.BaseCurrency),
+		TargetCurrency: string(rate.TargetCurrency),
+		Rate:           rate.Rate.String(),
+		BuyRate:        rate.BuyRate.String(),
+		SellRate:       rate.SellRate.String(),
+		Spread:         rate.Spread.String(),
+		Source:         rate.Source,
+		Provider:       rate.Provider,
+		ValidFrom:      timestamp(rate.ValidFrom),
+		ValidTo:        optionalTimestamp(rate.ValidTo),
+		LastUpdated:    timestamp(rate.LastUpdated),
+	}, nil
+}
+
+func (s *ForexServer) Convert(ctx context.Context, in *kydv1.ConvertRequest) (*kydv1.ConvertResponse, error) {
+	amount, err := decimal.NewFromString(in.Amount)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid amount")
+	}
+	req := forex.CalculateRequest{
+		Amount: amount.InexactFloat64(),
+		From:   domain.Currency(in.From),
+		To:     domain.Currency(in.To),
+	}
+	if err := s.validator.Validate(&req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	result, err := s.service.Calculate(ctx, &req)
+	if err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	return &kydv1.ConvertResponse{
+		SourceAmount:      result.SourceAmount.String(),
+		SourceCurrency:    string(result.SourceCurrency),
+		ConvertedAmount:   result.ConvertedAmount.String(),
+		ConvertedCurrency: string(result.ConvertedCurrency),
+		Rate:              result.Rate.String(),
+		FeeAmount:         result.FeeAmount.String(),
+		TotalAmount:       result.TotalAmount.String(),
+	}, nil
+}
@@ -0,0 +1,142 @@
+// Package grpc serves the internal gRPC APIs the services call each other
+// with: payments, wallets, forex and access-token introspection. The
+// definitions live in kydv1/*.proto; the Go code next to them is generated
+// (run go generate in this directory). Each server here is a thin adapter
+// over the same service the REST handlers use, so both APIs apply the same
+// rules.
+//
+// The APIs are for trusted callers inside the deployment. With
+// SERVER_USE_TLS set they are served over mutual TLS with the services'
+// certificates, like the REST listeners behind the gateway; see Dial for
+// the client side.
+package grpc
+
+//go:generate buf generate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"time"
+
+	"kyd/pkg/config"
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Dial connects to the gRPC listener at addr. With cfg.UseTLS the client
+// presents cfg's certificate and verifies the server's against cfg.CAFile.
+func Dial(addr string, cfg config.ServerConfig) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if cfg.UseTLS {
+		tlsConfig, err := clientTLS(cfg)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	return grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+}
+
+// clientTLS is the mutual TLS configuration of a gRPC client.
+func clientTLS(cfg config.ServerConfig) (*tls.Config, error) {
+	keyPair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, kyderrors.Wrap(err, "failed to load client keypair")
+	}
+	pool, err := loadCA(cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func loadCA(path string) (*x509.CertPool, error) {
+	caCert, err := os.ReadFile(path)
+	if err != nil {
+		return nil, kyderrors.Wrap(err, "failed to read CA file")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, kyderrors.New("CA file contains no certificates")
+	}
+	return pool, nil
+}
+
+// statusError turns a service error into a gRPC status. Errors the
+// services share map to their code; the rest get fallback.
+func statusError(err error, fallback codes.Code) error {
+	code := fallback
+	switch {
+	case errors.Is(err, kyderrors.ErrTransactionNotFound),
+		errors.Is(err, kyderrors.ErrWalletNotFound),
+		errors.Is(err, kyderrors.ErrUserNotFound),
+		errors.Is(err, kyderrors.ErrSettlementNotFound):
+		code = codes.NotFound
+	case errors.Is(err, kyderrors.ErrTransactionAlreadyExists),
+		errors.Is(err, kyderrors.ErrDuplicateRequest):
+		code = codes.AlreadyExists
+	case errors.Is(err, kyderrors.ErrInsufficientBalance),
+		errors.Is(err, kyderrors.ErrWalletNotActive),
+		errors.Is(err, kyderrors.ErrCurrencyNotAllowed):
+		code = codes.FailedPrecondition
+	case errors.Is(err, kyderrors.ErrRateNotAvailable),
+		errors.Is(err, kyderrors.ErrMovementFrozen):
+		code = codes.Unavailable
+	case errors.Is(err, kyderrors.ErrStepUpLocked):
+		code = codes.ResourceExhausted
+	case errors.Is(err, kyderrors.ErrJurisdictionRestricted):
+		code = codes.PermissionDenied
+	}
+	return status.Error(code, err.Error())
+}
+
+// parseID parses the UUID in field, which must be set.
+func parseID(field, raw string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+	}
+	return id, nil
+}
+
+// parseOptionalID is parseID for a field that may be empty.
+func parseOptionalID(field, raw string) (uuid.UUID, error) {
+	if raw == "" {
+		return uuid.Nil, nil
+	}
+	return parseID(field, raw)
+}
+
+func optionalID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
@@ -0,0 +1,289 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kyd/internal/auth"
+	"kyd/internal/domain"
+	"kyd/internal/grpc/kydv1"
+	"kyd/internal/payment"
+	"kyd/pkg/config"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakePayments struct {
+	initiated *payment.InitiatePaymentRequest
+	txs       map[uuid.UUID]*domain.Transaction
+}
+
+func (f *fakePayments) InitiatePayment(ctx context.Context, req *payment.InitiatePaymentRequest) (*payment.PaymentResponse, error) {
+	f.initiated = req
+	if req.Amount.GreaterThan(decimal.NewFromInt(1000)) {
+		return nil, kyderrors.ErrInsufficientBalance
+	}
+	tx := &domain.Transaction{
+		ID:         uuid.New(),
+		Reference:  req.Reference,
+		SenderID:   req.SenderID,
+		ReceiverID: req.ReceiverID,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+		Status:     domain.TransactionStatusCompleted,
+		CreatedAt:  time.Now(),
+	}
+	return &payment.PaymentResponse{Transaction: tx, Message: "Payment completed"}, nil
+}
+
+func (f *fakePayments) GetTransaction(ctx context.Context, id uuid.UUID) (*payment.TransactionDetail, error) {
+	tx, ok := f.txs[id]
+	if !ok {
+		return nil, kyderrors.ErrTransactionNotFound
+	}
+	return &payment.TransactionDetail{Transaction: tx}, nil
+}
+
+func (f *fakePayments) GetTransactionByReference(ctx context.Context, ref string) (*payment.TransactionDetail, error) {
+	return nil, kyderrors.ErrTransactionNotFound
+}
+
+func (f *fakePayments) GetUserTransactions(ctx context.Context, userID uuid.UUID, walletID *uuid.UUID, limit, offset int) ([]*payment.TransactionDetail, int, error) {
+	return nil, 0, nil
+}
+
+func (f *fakePayments) CancelTransaction(ctx context.Context, txID, userID uuid.UUID) error {
+	return nil
+}
+
+type maintenanceWindow struct{ active bool }
+
+func (m maintenanceWindow) InMaintenance(ctx context.Context, service string) (string, time.Duration, bool) {
+	return "Payments are paused for maintenance", time.Minute, m.active
+}
+
+type fakeTokens map[string]*auth.TokenInfo
+
+func (f fakeTokens) IntrospectToken(ctx context.Context, token string) (*auth.TokenInfo, error) {
+	if info, ok := f[token]; ok {
+		return info, nil
+	}
+	return &auth.TokenInfo{}, nil
+}
+
+// serve runs srv on an in-memory listener and returns a connection to it.
+func serve(t *testing.T, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestPaymentServer(t *testing.T) {
+	known := &domain.Transaction{ID: uuid.New(), Amount: decimal.RequireFromString("12.50"), Currency: "MWK", Status: domain.TransactionStatusPending}
+	payments := &fakePayments{txs: map[uuid.UUID]*domain.Transaction{known.ID: known}}
+	window := &maintenanceWindow{}
+	server := NewPaymentServer(payments, validator.New()).WithMaintenance(window)
+	client := kydv1.NewPaymentServiceClient(serve(t, func(s *grpc.Server) { kydv1.RegisterPaymentServiceServer(s, server) }))
+	ctx := context.Background()
+
+	sender, receiver := uuid.New(), uuid.New()
+	t.Run("initiate", func(t *testing.T) {
+		resp, err := client.InitiatePayment(ctx, &kydv1.InitiatePaymentRequest{
+			SenderId:   sender.String(),
+			ReceiverId: receiver.String(),
+			Amount:     "250.75",
+			Currency:   "MWK",
+			Reference:  "order-17",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "250.75", resp.Transaction.Amount)
+		assert.Equal(t, "completed", resp.Transaction.Status)
+		assert.Equal(t, "order-17", resp.Transaction.Reference)
+		assert.Nil(t, resp.Transaction.CompletedAt)
+		assert.True(t, payments.initiated.Amount.Equal(decimal.RequireFromString("250.75")))
+		assert.Equal(t, receiver, payments.initiated.ReceiverID)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		for name, req := range map[string]*kydv1.InitiatePaymentRequest{
+			"sender":   {SenderId: "nope", Amount: "1", Currency: "MWK"},
+			"amount":   {SenderId: sender.String(), Amount: "one", Currency: "MWK"},
+			"currency": {SenderId: sender.String(), Amount: "1"},
+		} {
+			_, err := client.InitiatePayment(ctx, req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+	})
+
+	t.Run("service errors", func(t *testing.T) {
+		_, err := client.InitiatePayment(ctx, &kydv1.InitiatePaymentRequest{SenderId: sender.String(), Amount: "5000", Currency: "MWK"})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+		_, err = client.GetTransaction(ctx, &kydv1.GetTransactionRequest{Id: uuid.NewString()})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		_, err = client.GetTransaction(ctx, &kydv1.GetTransactionRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("get", func(t *testing.T) {
+		tx, err := client.GetTransaction(ctx, &kydv1.GetTransactionRequest{Id: known.ID.String()})
+		require.NoError(t, err)
+		assert.Equal(t, "12.5", tx.Amount)
+		assert.Equal(t, "pending", tx.Status)
+		assert.Empty(t, tx.SenderWalletId)
+	})
+
+	t.Run("maintenance", func(t *testing.T) {
+		window.active = true
+		defer func() { window.active = false }()
+		_, err := client.InitiatePayment(ctx, &kydv1.InitiatePaymentRequest{SenderId: sender.String(), Amount: "1", Currency: "MWK"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestAuthServer_IntrospectToken(t *testing.T) {
+	userID := uuid.New()
+	exp := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	tokens := fakeTokens{"good": {Active: true, UserID: userID, Email: "a@example.com", UserType: domain.UserTypeIndividual, ExpiresAt: exp}}
+	client := kydv1.NewAuthServiceClient(serve(t, func(s *grpc.Server) { kydv1.RegisterAuthServiceServer(s, NewAuthServer(tokens)) }))
+	ctx := context.Background()
+
+	resp, err := client.IntrospectToken(ctx, &kydv1.IntrospectTokenRequest{Token: "good"})
+	require.NoError(t, err)
+	assert.True(t, resp.Active)
+	assert.Equal(t, userID.String(), resp.UserId)
+	assert.Equal(t, "individual", resp.UserType)
+	assert.True(t, exp.Equal(resp.ExpiresAt.AsTime()))
+
+	for _, token := range []string{"", "bad"} {
+		resp, err := client.IntrospectToken(ctx, &kydv1.IntrospectTokenRequest{Token: token})
+		require.NoError(t, err)
+		assert.False(t, resp.Active)
+		assert.Empty(t, resp.UserId)
+	}
+}
+
+// writeCerts writes a CA and a server and a client certificate it signed
+// into dir, returning the server and client configurations.
+func writeCerts(t *testing.T, dir string) (server, client config.ServerConfig) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kyd test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", caDER)
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) config.ServerConfig {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		cfg := config.ServerConfig{
+			UseTLS:   true,
+			CertFile: filepath.Join(dir, name+".pem"),
+			KeyFile:  filepath.Join(dir, name+"-key.pem"),
+			CAFile:   caFile,
+		}
+		writePEM(t, cfg.CertFile, "CERTIFICATE", der)
+		writePEM(t, cfg.KeyFile, "EC PRIVATE KEY", keyDER)
+		return cfg
+	}
+	return issue("server", 2, x509.ExtKeyUsageServerAuth), issue("client", 3, x509.ExtKeyUsageClientAuth)
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600))
+}
+
+func TestDial_MutualTLS(t *testing.T) {
+	serverCfg, clientCfg := writeCerts(t, t.TempDir())
+
+	keyPair, err := tls.LoadX509KeyPair(serverCfg.CertFile, serverCfg.KeyFile)
+	require.NoError(t, err)
+	pool, err := loadCA(serverCfg.CAFile)
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	kydv1.RegisterAuthServiceServer(srv, NewAuthServer(fakeTokens{}))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := Dial(lis.Addr().String(), clientCfg)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = kydv1.NewAuthServiceClient(conn).IntrospectToken(ctx, &kydv1.IntrospectTokenRequest{Token: "x"})
+	assert.NoError(t, err)
+
+	// Without a client certificate the handshake is refused.
+	noCert, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+	require.NoError(t, err)
+	defer noCert.Close()
+	_, err = kydv1.NewAuthServiceClient(noCert).IntrospectToken(ctx, &kydv1.IntrospectTokenRequest{Token: "x"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
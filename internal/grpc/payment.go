@@ -0,0 +1,214 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/grpc/kydv1"
+	"kyd/internal/maintenance"
+	"kyd/internal/payment"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PaymentService is the part of payment.Service the payment API serves.
+type PaymentService interface {
+	InitiatePayment(ctx context.Context, req *payment.InitiatePaymentRequest) (*payment.PaymentResponse, error)
+	GetTransaction(ctx context.Context, id uuid.UUID) (*payment.TransactionDetail, error)
+	GetTransactionByReference(ctx context.Context, ref string) (*payment.TransactionDetail, error)
+	GetUserTransactions(ctx context.Context, userID uuid.UUID, walletID *uuid.UUID, limit, offset int) ([]*payment.TransactionDetail, int, error)
+	CancelTransaction(ctx context.Context, txID, userID uuid.UUID) error
+}
+
+// MaintenanceChecker reports a maintenance window; satisfied by
+// maintenance.Mode.
+type MaintenanceChecker interface {
+	InMaintenance(ctx context.Context, service string) (string, time.Duration, bool)
+}
+
+// PaymentServer serves kyd.v1.PaymentService.
+type PaymentServer struct {
+	kydv1.UnimplementedPaymentServiceServer
+	service     PaymentService
+	validator   *validator.Validator
+	maintenance MaintenanceChecker
+}
+
+func NewPaymentServer(service PaymentService, val *validator.Validator) *PaymentServer {
+	return &PaymentServer{service: service, validator: val}
+}
+
+// WithMaintenance refuses new payments during the payment service's
+// maintenance windows, as the REST API does.
+func (s *PaymentServer) WithMaintenance(checker MaintenanceChecker) *PaymentServer {
+	s.maintenance = checker
+	return s
+}
+
+func (s *PaymentServer) InitiatePayment(ctx context.Context, in *kydv1.InitiatePaymentRequest) (*kydv1.InitiatePaymentResponse, error) {
+	if s.maintenance != nil {
+		if message, _, active := s.maintenance.InMaintenance(ctx, maintenance.ServicePayment); active {
+			return nil, status.Error(codes.Unavailable, message)
+		}
+	}
+	req := payment.InitiatePaymentRequest{
+		ReceiverWalletAddress: in.ReceiverWalletNumber,
+		Currency:              domain.Currency(in.Currency),
+		DestinationCurrency:   domain.Currency(in.DestinationCurrency),
+		Description:           in.Description,
+		Channel:               in.Channel,
+		Category:              in.Category,
+		Reference:             in.Reference,
+		DeviceID:              in.DeviceId,
+		Location:              in.Location,
+	}
+	var err error
+	if req.SenderID, err = parseID("sender_id", in.SenderId); err != nil {
+		return nil, err
+	}
+	if req.ReceiverID, err = parseOptionalID("receiver_id", in.ReceiverId); err != nil {
+		return nil, err
+	}
+	if req.ReceiverWalletID, err = parseOptionalID("receiver_wallet_id", in.ReceiverWalletId); err != nil {
+		return nil, err
+	}
+	if req.Amount, err = decimal.NewFromString(in.Amount); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid amount")
+	}
+	if err := s.validator.Validate(&req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp, err := s.service.InitiatePayment(ctx, &req)
+	if err != nil {
+		// As over REST, a payment refused for any other reason is the
+		// caller's to fix.
+		return nil, statusError(err, codes.InvalidArgument)
+	}
+	return &kydv1.InitiatePaymentResponse{
+		Transaction: transactionMessage(resp.Transaction),
+		Message:     resp.Message,
+		QueuedUntil: optionalTimestamp(resp.QueuedUntil),
+	}, nil
+}
+
+func (s *PaymentServer) GetTransaction(ctx context.Context, in *kydv1.GetTransactionRequest) (*kydv1.Transaction, error) {
+	var (
+		detail *payment.TransactionDetail
+		err    error
+	)
+	switch {
+	case in.Id != "":
+		id, perr := parseID("id", in.Id)
+		if perr != nil {
+			return nil, perr
+		}
+		detail, err = s.service.GetTransaction(ctx, id)
+	case in.Reference != "":
+		detail, err = s.service.GetTransactionByReference(ctx, in.Reference)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "id or reference is required")
+	}
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return transactionMessage(detail.Transaction), nil
+}
+
+func (s *PaymentServer) ListUserTransactions(ctx context.Context, in *kydv1.ListUserTransactionsRequest) (*kydv1.ListUserTransactionsResponse, error) {
+	userID, err := parseID("user_id", in.UserId)
+	if err != nil {
+		return nil, err
+	}
+	var walletID *uuid.UUID
+	if in.WalletId != "" {
+		id, err := parseID("wallet_id", in.WalletId)
+		if err != nil {
+			return nil, err
+		}
+		walletID = &id
+	}
+	limit, offset := pagination(in.Limit, in.Offset)
+
+	details, total, err := s.service.GetUserTransactions(ctx, userID, walletID, limit, offset)
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	out := &kydv1.ListUserTransactionsResponse{
+		Transactions: make([]*kydv1.Transaction, 0, len(details)),
+		Total:        int32(total),
+	}
+	for _, d := range details {
+		out.Transactions = append(out.Transactions, transactionMessage(d.Transaction))
+	}
+	return out, nil
+}
+
+func (s *PaymentServer) CancelTransaction(ctx context.Context, in *kydv1.CancelTransactionRequest) (*kydv1.CancelTransactionResponse, error) {
+	txID, err := parseID("transaction_id", in.TransactionId)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID("user_id", in.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.service.CancelTransaction(ctx, txID, userID); err != nil {
+		return nil, statusError(err, codes.FailedPrecondition)
+	}
+	return &kydv1.CancelTransactionResponse{}, nil
+}
+
+// pagination applies the REST defaults: 50 per page, at most 1000.
+func pagination(limit, offset int32) (int, int) {
+	l, o := int(limit), int(offset)
+	if l <= 0 {
+		l = 50
+	}
+	if l > 1000 {
+		l = 1000
+	}
+	if o < 0 {
+		o = 0
+	}
+	return l, o
+}
+
+func transactionMessage(tx *domain.Transaction) *kydv1.Transaction {
+	if tx == nil {
+		return nil
+	}
+	return &kydv1.Transaction{
+		Id:                tx.ID.String(),
+		Reference:         tx.Reference,
+		ReferenceNumber:   tx.ReferenceNumber,
+		SenderId:          tx.SenderID.String(),
+		ReceiverId:        tx.ReceiverID.String(),
+		SenderWalletId:    optionalID(tx.SenderWalletID),
+		ReceiverWalletId:  optionalID(tx.ReceiverWalletID),
+		Amount:            tx.Amount.String(),
+		Currency:          string(tx.Currency),
+		ExchangeRate:      tx.ExchangeRate.String(),
+		ConvertedAmount:   tx.ConvertedAmount.String(),
+		ConvertedCurrency: string(tx.ConvertedCurrency),
+		FeeAmount:         tx.FeeAmount.String(),
+		FeeCurrency:       string(tx.FeeCurrency),
+		NetAmount:         tx.NetAmount.String(),
+		Status:            string(tx.Status),
+		StatusReason:      tx.StatusReason,
+		TransactionType:   string(tx.TransactionType),
+		Channel:           tx.Channel,
+		Category:          tx.Category,
+		Description:       tx.Description,
+		SettlementId:      optionalID(tx.SettlementID),
+		InitiatedAt:       timestamp(tx.InitiatedAt),
+		CompletedAt:       optionalTimestamp(tx.CompletedAt),
+		CreatedAt:         timestamp(tx.CreatedAt),
+		UpdatedAt:         timestamp(tx.UpdatedAt),
+	}
+}
@@ -0,0 +1,118 @@
+package grpc
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/grpc/kydv1"
+	"kyd/internal/wallet"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WalletService is the part of wallet.Service the wallet API serves.
+type WalletService interface {
+	GetWallet(ctx context.Context, id uuid.UUID) (*domain.Wallet, error)
+	GetBalance(ctx context.Context, walletID uuid.UUID) (*wallet.BalanceResponse, error)
+	GetUserWallets(ctx context.Context, userID uuid.UUID) ([]*wallet.BalanceResponse, error)
+	LookupWallet(ctx context.Context, address string) (*wallet.LookupResponse, error)
+}
+
+// WalletServer serves kyd.v1.WalletService.
+type WalletServer struct {
+	kydv1.UnimplementedWalletServiceServer
+	service WalletService
+}
+
+func NewWalletServer(service WalletService) *WalletServer {
+	return &WalletServer{service: service}
+}
+
+func (s *WalletServer) GetWallet(ctx context.Context, in *kydv1.GetWalletRequest) (*kydv1.Wallet, error) {
+	id, err := parseID("wallet_id", in.WalletId)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.service.GetWallet(ctx, id)
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	out := &kydv1.Wallet{
+		Id:                w.ID.String(),
+		UserId:            w.UserID.String(),
+		Currency:          string(w.Currency),
+		AvailableBalance:  w.AvailableBalance.String(),
+		LedgerBalance:     w.LedgerBalance.String(),
+		ReservedBalance:   w.ReservedBalance.String(),
+		Status:            string(w.Status),
+		LastTransactionAt: optionalTimestamp(w.LastTransactionAt),
+		CreatedAt:         timestamp(w.CreatedAt),
+		UpdatedAt:         timestamp(w.UpdatedAt),
+	}
+	if w.WalletAddress != nil {
+		out.WalletAddress = *w.WalletAddress
+	}
+	return out, nil
+}
+
+func (s *WalletServer) GetBalance(ctx context.Context, in *kydv1.GetBalanceRequest) (*kydv1.Balance, error) {
+	id, err := parseID("wallet_id", in.WalletId)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.service.GetBalance(ctx, id)
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return balanceMessage(b), nil
+}
+
+func (s *WalletServer) ListUserWallets(ctx context.Context, in *kydv1.ListUserWalletsRequest) (*kydv1.ListUserWalletsResponse, error) {
+	userID, err := parseID("user_id", in.UserId)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.service.GetUserWallets(ctx, userID)
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	out := &kydv1.ListUserWalletsResponse{Wallets: make([]*kydv1.Balance, 0, len(balances))}
+	for _, b := range balances {
+		out.Wallets = append(out.Wallets, balanceMessage(b))
+	}
+	return out, nil
+}
+
+func (s *WalletServer) LookupWallet(ctx context.Context, in *kydv1.LookupWalletRequest) (*kydv1.LookupWalletResponse, error) {
+	if in.WalletNumber == "" {
+		return nil, status.Error(codes.InvalidArgument, "wallet_number is required")
+	}
+	l, err := s.service.LookupWallet(ctx, in.WalletNumber)
+	if err != nil {
+		return nil, statusError(err, codes.NotFound)
+	}
+	return &kydv1.LookupWalletResponse{
+		Name:     l.Name,
+		Currency: string(l.Currency),
+		Address:  l.Address,
+	}, nil
+}
+
+func balanceMessage(b *wallet.BalanceResponse) *kydv1.Balance {
+	out := &kydv1.Balance{
+		WalletId:               b.WalletID.String(),
+		FormattedWalletAddress: b.FormattedWalletAddress,
+		Currency:               string(b.Currency),
+		AvailableBalance:       b.AvailableBalance.String(),
+		LedgerBalance:          b.LedgerBalance.String(),
+		ReservedBalance:        b.ReservedBalance.String(),
+		Status:                 string(b.Status),
+		CreatedAt:              timestamp(b.CreatedAt),
+	}
+	if b.WalletAddress != nil {
+		out.WalletAddress = *b.WalletAddress
+	}
+	return out
+}
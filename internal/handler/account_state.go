@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// GetStateAt rebuilds a user's wallet balances and limit usage at
+// ?at=<RFC 3339>, for support investigations; ?reason= is recorded with
+// the audited view (admin).
+func (h *UsersHandler) GetStateAt(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	q := r.URL.Query()
+	at, err := time.Parse(time.RFC3339, q.Get("at"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid at timestamp")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	st, err := h.paymentSvc.AccountStateAt(r.Context(), userID, at, payment.AccountStateViewer{
+		AdminID: adminID,
+		IP:      middleware.ClientIP(r),
+		Reason:  q.Get("reason"),
+	})
+	switch {
+	case err == nil:
+		w.Header().Set("Cache-Control", "private, no-store")
+		respondJSON(w, http.StatusOK, st)
+	case errors.Is(err, pkgerrors.ErrUserNotFound):
+		respondError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, payment.ErrInvalidStateTime):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, payment.ErrAccountStateDisabled):
+		respondError(w, http.StatusNotImplemented, err.Error())
+	default:
+		h.logger.Error("Failed to rebuild account state", map[string]interface{}{"error": err.Error(), "user_id": userID})
+		respondError(w, http.StatusInternalServerError, "Failed to rebuild account state")
+	}
+}
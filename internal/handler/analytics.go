@@ -3,17 +3,21 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"kyd/internal/analytics"
+	"kyd/pkg/clock"
 	"kyd/pkg/logger"
 )
 
 // AnalyticsHandler manages analytics endpoints.
 type AnalyticsHandler struct {
-	engine *analytics.AnalyticsEngine
-	logger logger.Logger
+	engine  *analytics.AnalyticsEngine
+	reports *analytics.Reports
+	logger  logger.Logger
 }
 
 // NewAnalyticsHandler creates an AnalyticsHandler.
@@ -24,6 +28,12 @@ func NewAnalyticsHandler(engine *analytics.AnalyticsEngine, log logger.Logger) *
 	}
 }
 
+// WithReports enables the precomputed funnel and retention endpoints.
+func (h *AnalyticsHandler) WithReports(reports *analytics.Reports) *AnalyticsHandler {
+	h.reports = reports
+	return h
+}
+
 // AnalyzeFraudRequest is the request body for fraud analysis.
 type AnalyzeFraudRequest struct {
 	TransactionID   string  `json:"transaction_id"`
@@ -46,7 +56,8 @@ func (h *AnalyticsHandler) AnalyzeFraud(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	now := time.Now()
+	// Hour and weekday features describe the platform's business day.
+	now := clock.BusinessNow()
 
 	features := analytics.TransactionFeatures{
 		ID:              req.TransactionID,
@@ -57,7 +68,7 @@ func (h *AnalyticsHandler) AnalyzeFraud(w http.ResponseWriter, r *http.Request)
 		Timestamp:       now,
 		Hour:            now.Hour(),
 		DayOfWeek:       int(now.Weekday()),
-		IsWeekend:       now.Weekday() == time.Saturday || now.Weekday() == time.Sunday,
+		IsWeekend:       clock.IsWeekend(now),
 		CountryCode:     req.CountryCode,
 		DeviceHash:      req.DeviceHash,
 		RecipientHash:   req.RecipientID,
@@ -170,6 +181,105 @@ func (h *AnalyticsHandler) GetEarningsReport(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// reportRange reads the inclusive from/to dates (YYYY-MM-DD) of a report and
+// returns them as a half-open range. Missing dates default to the defaultDays
+// up to and including today.
+func reportRange(r *http.Request, defaultDays int) (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -defaultDays+1), today
+	if v := r.URL.Query().Get("from"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = d
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = d
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// GetFunnel returns registration→KYC→first payment conversion per corridor.
+func (h *AnalyticsHandler) GetFunnel(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	from, to, err := reportRange(r, 30)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+		return
+	}
+	report, err := h.reports.Funnel(r.Context(), from, to, strings.ToUpper(r.URL.Query().Get("corridor")))
+	if err != nil {
+		h.respondReportError(w, err, "funnel")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, report)
+}
+
+// GetRetention returns monthly retention of sender cohorts.
+func (h *AnalyticsHandler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	from, to, err := reportRange(r, 365)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+		return
+	}
+	report, err := h.reports.Retention(r.Context(), from, to, strings.ToUpper(r.URL.Query().Get("corridor")))
+	if err != nil {
+		h.respondReportError(w, err, "retention")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, report)
+}
+
+// RebuildAggregates recomputes the analytics aggregates from a given date,
+// for backfilling history the daily job does not revisit.
+func (h *AnalyticsHandler) RebuildAggregates(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	var req struct {
+		From string `json:"from"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	since, err := time.Parse("2006-01-02", req.From)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+		return
+	}
+	if err := h.reports.Rebuild(r.Context(), since); err != nil {
+		h.respondReportError(w, err, "rebuild")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]string{"status": "rebuilt", "from": req.From})
+}
+
+func (h *AnalyticsHandler) respondReportError(w http.ResponseWriter, err error, action string) {
+	if errors.Is(err, analytics.ErrInvalidRange) {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.logger.Error("Analytics report failed", map[string]interface{}{"error": err.Error(), "action": action})
+	h.respondError(w, http.StatusInternalServerError, "Failed to load analytics")
+}
+
 func (h *AnalyticsHandler) respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
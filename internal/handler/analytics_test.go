@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWTSecret = "test-secret"
+
+// authenticated runs h behind the real auth middleware for a caller with
+// the given claims.
+func authenticated(t *testing.T, h http.HandlerFunc, claims jwt.MapClaims, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	claims["user_id"] = uuid.New().String()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	middleware.NewAuthMiddleware(testJWTSecret, nil).Authenticate(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAnalyticsReports_RequireAdmin(t *testing.T) {
+	// No reports are configured: reaching them would panic.
+	h := NewAnalyticsHandler(nil, logger.NewNop())
+
+	endpoints := map[string]struct {
+		handler http.HandlerFunc
+		method  string
+		body    string
+	}{
+		"funnel":    {h.GetFunnel, http.MethodGet, ""},
+		"retention": {h.GetRetention, http.MethodGet, ""},
+		"rebuild":   {h.RebuildAggregates, http.MethodPost, `{"from":"2026-01-01"}`},
+	}
+	for name, e := range endpoints {
+		for _, claims := range []jwt.MapClaims{{"user_type": "individual"}, {"user_type": "merchant"}, {}} {
+			req := httptest.NewRequest(e.method, "/admin/analytics/"+name, strings.NewReader(e.body))
+			rec := authenticated(t, e.handler, claims, req)
+			assert.Equal(t, http.StatusForbidden, rec.Code, "%s as %v", name, claims["user_type"])
+		}
+	}
+}
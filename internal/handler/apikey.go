@@ -2,9 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"kyd/internal/auth"
+	"kyd/internal/domain"
 	"kyd/internal/middleware"
 	"kyd/pkg/logger"
 
@@ -25,8 +27,10 @@ func NewAPIKeyHandler(service *auth.APIKeyService, log logger.Logger) *APIKeyHan
 }
 
 type CreateAPIKeyRequest struct {
-	Name   string   `json:"name"`
-	Scopes []string `json:"scopes"`
+	Name        string                `json:"name"`
+	Scopes      []string              `json:"scopes"`
+	OwnerID     *uuid.UUID            `json:"owner_id"`    // customer account the key acts for
+	Environment domain.APIEnvironment `json:"environment"` // production (default) or sandbox
 }
 
 func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
@@ -49,7 +53,15 @@ func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	key, rawKey, err := h.service.CreateKey(r.Context(), req.Name, req.Scopes, userID)
+	key, rawKey, err := h.service.CreateKey(r.Context(), req.Name, req.Scopes, userID, req.OwnerID, req.Environment)
+	if errors.Is(err, auth.ErrAPIAccessNotEntitled) {
+		h.respondError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidEnvironment) {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to create API key", map[string]interface{}{"error": err.Error()})
 		h.respondError(w, http.StatusInternalServerError, "Failed to create API key")
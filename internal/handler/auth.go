@@ -2,14 +2,17 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"image/png"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -99,6 +102,10 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 			h.respondError(w, http.StatusConflict, "User already exists")
 			return
 		}
+		if ineligible, ok := asIneligible(err); ok {
+			respondIneligible(w, ineligible)
+			return
+		}
 
 		h.respondError(w, http.StatusInternalServerError, "Registration failed")
 		return
@@ -233,11 +240,14 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 				description := fmt.Sprintf("Login failed for %s from %s (%s)", req.Email, ip, req.CountryCode)
 
+				// Attribute the attempt to the account so its owner sees it in their login history.
+				userID := h.service.UserIDByEmail(context.Background(), req.Email)
+
 				_ = h.securityService.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
 					Type:        domain.SecurityEventTypeAdminLoginFailed,
 					Severity:    severity,
 					Description: description,
-					UserID:      nil,
+					UserID:      userID,
 					IPAddress:   ip,
 					Location:    req.CountryCode,
 					Status:      domain.SecurityEventStatusOpen,
@@ -249,16 +259,40 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 						"risk_score":   riskScore,
 						"error":        errMsg,
 						"country_code": req.CountryCode,
+						"channel":      security.LoginChannel(ua),
 					},
 					CreatedAt: time.Now(),
 				})
 			}(req, ip, ua, err.Error())
 		}
 
-		h.respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		switch err {
+		case auth.ErrMFAMethodUnavailable:
+			h.respondError(w, http.StatusForbidden, "Two-factor authentication is required but no method is available, contact support")
+		case errors.ErrStepUpLocked:
+			h.respondError(w, http.StatusTooManyRequests, "Too many failed verification attempts, try again later")
+		default:
+			h.respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		}
+		return
+	}
+
+	if response.MFA != nil {
+		// Password accepted; no tokens or cookies until the second factor
+		h.logger.Info("Login awaiting second factor", map[string]interface{}{
+			"event": "login_mfa_required",
+			"email": req.Email,
+		})
+		h.respondJSON(w, http.StatusOK, response.MFA)
 		return
 	}
 
+	h.loginSucceeded(w, r, &req, response)
+}
+
+// loginSucceeded logs and audits a login that passed every factor, sets the
+// auth cookies and responds with the tokens.
+func (h *AuthHandler) loginSucceeded(w http.ResponseWriter, r *http.Request, req *auth.LoginRequest, response *auth.TokenResponse) {
 	ip := req.IPAddress
 	if ip == "" {
 		ip = r.Header.Get("X-Forwarded-For")
@@ -292,6 +326,24 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	if h.securityService != nil && response.User != nil {
 		go func(user *domain.User, req auth.LoginRequest, ip, ua string) {
+			_ = h.securityService.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
+				Type:        domain.SecurityEventTypeLoginSuccess,
+				Severity:    domain.SecuritySeverityLow,
+				Description: fmt.Sprintf("Login for user %s", user.ID),
+				UserID:      &user.ID,
+				IPAddress:   ip,
+				Location:    req.CountryCode,
+				Status:      domain.SecurityEventStatusResolved,
+				Metadata: domain.Metadata{
+					"device_id":    req.DeviceID,
+					"device_name":  req.DeviceName,
+					"user_agent":   ua,
+					"country_code": req.CountryCode,
+					"channel":      security.LoginChannel(ua),
+				},
+				CreatedAt: time.Now(),
+			})
+
 			riskScore := 10
 			if req.CountryCode != "" && !strings.EqualFold(req.CountryCode, user.CountryCode) {
 				riskScore += 60
@@ -330,7 +382,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 					CreatedAt: time.Now(),
 				})
 			}
-		}(response.User, req, ip, ua)
+		}(response.User, *req, ip, ua)
 	}
 
 	// Set httpOnly cookies for access and refresh tokens
@@ -339,6 +391,38 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, response)
 }
 
+// LoginHistory returns the authenticated user's recent sign-in attempts so
+// they can spot logins they do not recognise.
+func (h *AuthHandler) LoginHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if h.securityService == nil {
+		h.respondError(w, http.StatusServiceUnavailable, "Login history unavailable")
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	entries, err := h.securityService.GetLoginHistory(r.Context(), userID, limit)
+	if err != nil {
+		h.logger.Error("Failed to load login history", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": userID,
+		})
+		h.respondError(w, http.StatusInternalServerError, "Failed to load login history")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"logins": entries})
+}
+
 // Me returns the authenticated user's profile based on JWT.
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromContext(r.Context())
@@ -566,10 +650,14 @@ func (h *AuthHandler) SendVerification(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.SendVerificationByEmail(r.Context(), req.Email); err != nil {
-		h.logger.Error("Failed to send verification email", map[string]interface{}{
-			"error": err.Error(),
-			"email": req.Email,
-		})
+		if err == errors.ErrVerificationThrottled {
+			h.logger.Info("Verification resend throttled", map[string]interface{}{"email": req.Email})
+		} else {
+			h.logger.Error("Failed to send verification email", map[string]interface{}{
+				"error": err.Error(),
+				"email": req.Email,
+			})
+		}
 		// Do not return error to user to prevent enumeration
 	}
 	h.respondJSON(w, http.StatusAccepted, map[string]string{"status": "verification email requested"})
@@ -621,6 +709,79 @@ func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, map[string]string{"status": "email verified"})
 }
 
+// SendPhoneVerification sends an SMS code to the authenticated user's phone.
+func (h *AuthHandler) SendPhoneVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.service.SendPhoneVerification(r.Context(), userID); err != nil {
+		if err == errors.ErrVerificationThrottled {
+			h.respondError(w, http.StatusTooManyRequests, "A code was sent recently, please wait before requesting another")
+			return
+		}
+		h.logger.Error("Failed to send phone verification code", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": userID,
+		})
+		h.respondError(w, http.StatusInternalServerError, "Failed to send verification code")
+		return
+	}
+	h.respondJSON(w, http.StatusAccepted, map[string]string{"status": "verification code sent"})
+}
+
+type verifyPhoneRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// VerifyPhone confirms the authenticated user's phone number with an SMS code.
+func (h *AuthHandler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req verifyPhoneRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		h.respondValidationErrors(w, errs)
+		return
+	}
+
+	if err := h.service.VerifyPhone(r.Context(), userID, req.Code); err != nil {
+		switch err {
+		case errors.ErrInvalidVerificationCode:
+			h.respondError(w, http.StatusBadRequest, "Invalid or expired code")
+		case errors.ErrTooManyCodeAttempts:
+			h.respondError(w, http.StatusTooManyRequests, "Too many attempts, please request a new code")
+		default:
+			h.logger.Error("Phone verification failed", map[string]interface{}{
+				"error":   err.Error(),
+				"user_id": userID,
+			})
+			h.respondError(w, http.StatusInternalServerError, "Verification failed")
+		}
+		return
+	}
+
+	h.logger.Info("Phone verified", map[string]interface{}{
+		"event":   "phone_verified",
+		"user_id": userID,
+	})
+
+	h.respondJSON(w, http.StatusOK, map[string]string{"status": "phone verified"})
+}
+
 // ForgotPasswordRequest captures the email for password reset request.
 type ForgotPasswordRequest struct {
 	Email string `json:"email" validate:"required,email"`
@@ -681,6 +842,76 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Password updated successfully"})
 }
 
+// Invitation returns the provisional account behind an invitation link, so
+// the signup page can be pre-filled.
+func (h *AuthHandler) Invitation(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.respondError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+	user, err := h.service.Invitation(r.Context(), token)
+	if err != nil {
+		h.respondInvitationError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"email":        user.Email,
+		"phone":        user.Phone,
+		"first_name":   user.FirstName,
+		"last_name":    user.LastName,
+		"country_code": user.CountryCode,
+	})
+}
+
+// AcceptInvitationRequest captures the invitation token and the password
+// the holder chose.
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// AcceptInvitation activates a provisional account and signs its holder in.
+func (h *AuthHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		h.respondValidationErrors(w, errs)
+		return
+	}
+
+	response, err := h.service.AcceptInvitation(r.Context(), req.Token, req.Password)
+	if err != nil {
+		h.respondInvitationError(w, r, err)
+		return
+	}
+	h.logger.Info("Invitation accepted", map[string]interface{}{
+		"event":   "invitation_accepted",
+		"user_id": response.User.ID,
+	})
+	h.setAuthCookies(w, response)
+	h.respondJSON(w, http.StatusOK, response)
+}
+
+func (h *AuthHandler) respondInvitationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case auth.ErrInvalidInvitation:
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case auth.ErrInvitationAccepted:
+		h.respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Invitation failed", map[string]interface{}{
+			"error": err.Error(),
+			"ip":    r.RemoteAddr,
+		})
+		h.respondError(w, http.StatusBadRequest, "Invitation failed: "+err.Error())
+	}
+}
+
 func (h *AuthHandler) respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -809,6 +1040,10 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 
 type totpSetupResponse struct {
 	OTPURL string `json:"otp_url"`
+	// Secret is for typing in when the QR code cannot be scanned.
+	Secret string `json:"secret"`
+	// QRCode is otp_url as a PNG data URI.
+	QRCode string `json:"qr_code"`
 }
 
 type totpVerifyRequest struct {
@@ -849,7 +1084,22 @@ func (h *AuthHandler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, totpSetupResponse{OTPURL: key.URL()})
+	img, err := key.Image(200, 200)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "TOTP setup failed")
+		return
+	}
+	var qr bytes.Buffer
+	if err := png.Encode(&qr, img); err != nil {
+		h.respondError(w, http.StatusInternalServerError, "TOTP setup failed")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, totpSetupResponse{
+		OTPURL: key.URL(),
+		Secret: secret,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+	})
 }
 
 func (h *AuthHandler) VerifyTOTP(w http.ResponseWriter, r *http.Request) {
@@ -916,7 +1166,20 @@ func (h *AuthHandler) VerifyTOTP(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"status": "totp_verified"})
+	// Enrollment hands out the first backup codes; they are not shown again
+	resp := map[string]interface{}{"status": "totp_verified"}
+	codes, err := h.service.RegenerateBackupCodes(r.Context(), user.ID)
+	switch {
+	case err == nil:
+		resp["backup_codes"] = codes
+	case err != auth.ErrMFANotConfigured:
+		h.logger.Error("Failed to issue backup codes", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": user.ID,
+		})
+	}
+
+	h.respondJSON(w, http.StatusOK, resp)
 }
 
 func (h *AuthHandler) TOTPStatus(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/billing"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// BillingHandler serves invoices and metered usage.
+type BillingHandler struct {
+	service *billing.Service
+	logger  logger.Logger
+}
+
+func NewBillingHandler(service *billing.Service, log logger.Logger) *BillingHandler {
+	return &BillingHandler{service: service, logger: log}
+}
+
+// ListInvoices returns the caller's invoice history, newest first.
+func (h *BillingHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	limit, offset := parsePagination(r)
+	invoices, err := h.service.ListInvoices(r.Context(), billing.InvoiceFilter{
+		UserID: &userID,
+		Status: domain.InvoiceStatus(r.URL.Query().Get("status")),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		h.respondBillingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"invoices": invoices, "limit": limit, "offset": offset})
+}
+
+// GetInvoice returns one of the caller's invoices with its lines.
+func (h *BillingHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	inv, err := h.service.GetInvoice(r.Context(), userID, id)
+	if err != nil {
+		h.respondBillingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, inv)
+}
+
+// PayInvoice debits an unpaid invoice from the caller's wallet now.
+func (h *BillingHandler) PayInvoice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	inv, err := h.service.PayInvoice(r.Context(), userID, id)
+	if err != nil {
+		h.respondBillingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, inv)
+}
+
+// Usage returns the caller's metered usage so far this month.
+func (h *BillingHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	usage, err := h.service.Usage(r.Context(), userID)
+	if err != nil {
+		h.respondBillingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"usage": usage})
+}
+
+// AdminListInvoices lists invoices across accounts, optionally for one
+// user_id or status (admin).
+func (h *BillingHandler) AdminListInvoices(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	filter := billing.InvoiceFilter{
+		Status: domain.InvoiceStatus(r.URL.Query().Get("status")),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		filter.UserID = &id
+	}
+	invoices, err := h.service.ListInvoices(r.Context(), filter)
+	if err != nil {
+		h.respondBillingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"invoices": invoices, "limit": limit, "offset": offset})
+}
+
+type markInvoicePaidRequest struct {
+	Reference string `json:"reference"`
+}
+
+// MarkInvoicePaid records an invoice paid outside the platform (admin).
+func (h *BillingHandler) MarkInvoicePaid(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	var req markInvoicePaidRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	inv, err := h.service.MarkPaidExternally(r.Context(), id, req.Reference, adminID)
+	if err != nil {
+		h.respondBillingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, inv)
+}
+
+func (h *BillingHandler) respondBillingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, billing.ErrInvoiceNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, billing.ErrInvoiceNotCollectible):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, billing.ErrInvalidReference):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pkgerrors.ErrInsufficientBalance), errors.Is(err, pkgerrors.ErrWalletNotFound):
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		h.logger.Error("Billing request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process billing request")
+	}
+}
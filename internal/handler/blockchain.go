@@ -9,7 +9,6 @@ import (
 	"kyd/internal/blockchain"
 	"kyd/internal/domain"
 	"kyd/internal/ledger"
-	"kyd/internal/middleware"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -26,8 +25,7 @@ func NewBlockchainHandler(service *blockchain.Service, ledgerService *ledger.Ser
 
 func (h *BlockchainHandler) ListNetworks(w http.ResponseWriter, r *http.Request) {
 	// Admin check
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -44,8 +42,7 @@ func (h *BlockchainHandler) ListNetworks(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *BlockchainHandler) CreateNetwork(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -65,8 +62,7 @@ func (h *BlockchainHandler) CreateNetwork(w http.ResponseWriter, r *http.Request
 }
 
 func (h *BlockchainHandler) UpdateNetwork(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -131,8 +127,7 @@ func (h *BlockchainHandler) UpdateNetwork(w http.ResponseWriter, r *http.Request
 }
 
 func (h *BlockchainHandler) GetNetwork(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -150,8 +145,7 @@ func (h *BlockchainHandler) GetNetwork(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *BlockchainHandler) DeleteNetwork(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -169,8 +163,7 @@ func (h *BlockchainHandler) DeleteNetwork(w http.ResponseWriter, r *http.Request
 
 // VerifyLedgerChain verifies the hash chain integrity for a given wallet.
 func (h *BlockchainHandler) VerifyLedgerChain(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -202,8 +195,7 @@ func (h *BlockchainHandler) VerifyLedgerChain(w http.ResponseWriter, r *http.Req
 
 // GetLedgerChainReport returns a data-driven view of hash linkage for the last N ledger entries.
 func (h *BlockchainHandler) GetLedgerChainReport(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
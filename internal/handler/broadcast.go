@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"kyd/internal/broadcast"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type BroadcastHandler struct {
+	service *broadcast.Service
+	logger  logger.Logger
+}
+
+func NewBroadcastHandler(service *broadcast.Service, log logger.Logger) *BroadcastHandler {
+	return &BroadcastHandler{service: service, logger: log}
+}
+
+func (h *BroadcastHandler) respondBroadcastError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, broadcast.ErrInvalidBroadcast):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, broadcast.ErrNotCancellable):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, broadcast.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Broadcast not found")
+	default:
+		h.logger.Error("Failed to "+action+" broadcast", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to "+action+" broadcast")
+	}
+}
+
+func (h *BroadcastHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	actorID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req struct {
+		Title       string                  `json:"title"`
+		Message     string                  `json:"message"`
+		Segment     domain.BroadcastSegment `json:"segment"`
+		SegmentID   *uuid.UUID              `json:"segment_id"`
+		Channels    []string                `json:"channels"`
+		ScheduledAt *time.Time              `json:"scheduled_at"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	b, err := h.service.Create(r.Context(), &broadcast.CreateRequest{
+		Title:       req.Title,
+		Message:     req.Message,
+		Segment:     req.Segment,
+		SegmentID:   req.SegmentID,
+		Channels:    req.Channels,
+		ScheduledAt: req.ScheduledAt,
+		CreatedBy:   &actorID,
+	})
+	if err != nil {
+		h.respondBroadcastError(w, err, "create")
+		return
+	}
+	respondJSON(w, http.StatusCreated, b)
+}
+
+func (h *BroadcastHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+
+	items, total, err := h.service.List(r.Context(), limit, offset)
+	if err != nil {
+		h.respondBroadcastError(w, err, "list")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Get returns a broadcast with its delivery and read rates.
+func (h *BroadcastHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid broadcast ID")
+		return
+	}
+
+	b, stats, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondBroadcastError(w, err, "get")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"broadcast": b,
+		"stats":     stats,
+	})
+}
+
+func (h *BroadcastHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid broadcast ID")
+		return
+	}
+
+	if err := h.service.Cancel(r.Context(), id); err != nil {
+		h.respondBroadcastError(w, err, "cancel")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Broadcast cancelled"})
+}
+
+// Preview reports how many users a segment matches before a broadcast is composed.
+func (h *BroadcastHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var segment domain.BroadcastSegment
+	if err := decodeStrict(w, r, &segment); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	n, err := h.service.Preview(r.Context(), segment)
+	if err != nil {
+		h.respondBroadcastError(w, err, "preview")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]int{"recipients": n})
+}
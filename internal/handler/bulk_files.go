@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/filechannel"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// BulkFilesHandler lets admins look up the payment files corporates sent
+// through the file channel, for dispute resolution.
+type BulkFilesHandler struct {
+	service *filechannel.Service
+	logger  logger.Logger
+}
+
+func NewBulkFilesHandler(service *filechannel.Service, log logger.Logger) *BulkFilesHandler {
+	return &BulkFilesHandler{service: service, logger: log}
+}
+
+// List returns received files, newest first, optionally for one customer.
+func (h *BulkFilesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var userID *uuid.UUID
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		userID = &id
+	}
+	limit, offset := parsePagination(r)
+	files, total, err := h.service.List(r.Context(), userID, limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list bulk files", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to list bulk files")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  files,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *BulkFilesHandler) get(w http.ResponseWriter, r *http.Request) (*domain.BulkFile, bool) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return nil, false
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid file ID")
+		return nil, false
+	}
+	f, err := h.service.Get(r.Context(), id)
+	if errors.Is(err, pkgerrors.ErrBulkFileNotFound) {
+		respondError(w, http.StatusNotFound, "Bulk file not found")
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("Failed to get bulk file", map[string]interface{}{"error": err.Error(), "file_id": id})
+		respondError(w, http.StatusInternalServerError, "Failed to get bulk file")
+		return nil, false
+	}
+	return f, true
+}
+
+// Get returns one file's record: its hash, format, errors and result.
+func (h *BulkFilesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if f, ok := h.get(w, r); ok {
+		respondJSON(w, http.StatusOK, f)
+	}
+}
+
+// Content returns the file exactly as it was received, with its SHA-256.
+func (h *BulkFilesHandler) Content(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.get(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.FileName))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Content-SHA256", f.SHA256)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(f.Content)
+}
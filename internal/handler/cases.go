@@ -23,8 +23,7 @@ func NewCasesHandler(service *casework.Service) *CasesHandler {
 }
 
 func (h *CasesHandler) List(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -69,8 +68,7 @@ func (h *CasesHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *CasesHandler) Create(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -141,8 +139,7 @@ func (h *CasesHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *CasesHandler) Get(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -163,8 +160,7 @@ func (h *CasesHandler) Get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *CasesHandler) Update(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -240,8 +236,7 @@ func (h *CasesHandler) Update(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *CasesHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"kyd/internal/complaint"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ComplaintHandler serves the complaints register: customers' complaints
+// and the staff handling them.
+type ComplaintHandler struct {
+	service *complaint.Service
+	logger  logger.Logger
+}
+
+func NewComplaintHandler(service *complaint.Service, log logger.Logger) *ComplaintHandler {
+	return &ComplaintHandler{service: service, logger: log}
+}
+
+// Submit records a complaint from the caller.
+func (h *ComplaintHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req complaint.SubmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	c, err := h.service.Submit(r.Context(), userID, &req)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, c)
+}
+
+// ListMine returns the caller's complaints, newest first.
+func (h *ComplaintHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.List(r.Context(), userID, limit, offset)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// GetMine returns one of the caller's complaints.
+func (h *ComplaintHandler) GetMine(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid complaint ID")
+		return
+	}
+	c, err := h.service.Get(r.Context(), userID, id)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+// Log records a complaint received outside the app (admin).
+func (h *ComplaintHandler) Log(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req complaint.LogRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	staffID, _ := middleware.UserIDFromContext(r.Context())
+	c, err := h.service.Log(r.Context(), staffID, &req)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, c)
+}
+
+// List searches the register (admin). ?overdue=true keeps complaints past
+// either deadline.
+func (h *ComplaintHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	q := r.URL.Query()
+	filter := domain.ComplaintFilter{
+		Status:   domain.ComplaintStatus(q.Get("status")),
+		Category: domain.ComplaintCategory(q.Get("category")),
+		Overdue:  q.Get("overdue") == "true",
+	}
+	switch filter.Status {
+	case "", domain.ComplaintReceived, domain.ComplaintAcknowledged, domain.ComplaintResolved:
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	if filter.Category != "" && !filter.Category.IsValid() {
+		respondError(w, http.StatusBadRequest, "Invalid category")
+		return
+	}
+	if v := q.Get("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		filter.UserID = &id
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.Search(r.Context(), filter, limit, offset)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// Get returns any complaint (admin).
+func (h *ComplaintHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid complaint ID")
+		return
+	}
+	c, err := h.service.Find(r.Context(), id)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+// Acknowledge takes a complaint on, assigning it to assigned_to or to the
+// caller (admin).
+func (h *ComplaintHandler) Acknowledge(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid complaint ID")
+		return
+	}
+	var req struct {
+		AssignedTo *uuid.UUID `json:"assigned_to"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	staffID, _ := middleware.UserIDFromContext(r.Context())
+	c, err := h.service.Acknowledge(r.Context(), staffID, id, req.AssignedTo)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+// Resolve closes a complaint with its outcome and root cause (admin).
+func (h *ComplaintHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid complaint ID")
+		return
+	}
+	var req complaint.ResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	staffID, _ := middleware.UserIDFromContext(r.Context())
+	c, err := h.service.Resolve(r.Context(), staffID, id, &req)
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+// ListReturns lists the quarterly returns kept so far (admin).
+func (h *ComplaintHandler) ListReturns(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	items, err := h.service.ListReturns(r.Context())
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// DownloadReturn serves a quarter's return to the Reserve Bank of Malawi
+// as CSV (admin).
+func (h *ComplaintHandler) DownloadReturn(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	ret, err := h.service.Return(r.Context(), mux.Vars(r)["period"])
+	if err != nil {
+		h.respondComplaintError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "complaints-return-"+ret.Period+".csv"))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(ret.Content)
+}
+
+func (h *ComplaintHandler) respondComplaintError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, complaint.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Complaint not found")
+	case errors.Is(err, complaint.ErrInvalidComplaint), errors.Is(err, complaint.ErrInvalidPeriod):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, complaint.ErrInvalidStatus):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Complaint request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"os"
@@ -10,6 +11,7 @@ import (
 
 	"kyd/internal/compliance"
 	"kyd/internal/middleware"
+	pkgerrors "kyd/pkg/errors"
 	"kyd/pkg/logger"
 
 	"github.com/google/uuid"
@@ -50,38 +52,8 @@ func (h *ComplianceHandler) SubmitKYC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle file upload
-	file, handler, err := r.FormFile("documents") // Frontend sends 'documents'
-	if err != nil {
-		h.respondError(w, http.StatusBadRequest, "Missing document file")
-		return
-	}
-	defer file.Close()
-
-	// Ensure upload directory exists
-	uploadDir := "./uploads/kyc"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		h.logger.Error("Failed to create upload directory", map[string]interface{}{"error": err.Error()})
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	// Generate filename
-	ext := filepath.Ext(handler.Filename)
-	filename := uuid.New().String() + ext
-	filePath := filepath.Join(uploadDir, filename)
-
-	dst, err := os.Create(filePath)
-	if err != nil {
-		h.logger.Error("Failed to create file", map[string]interface{}{"error": err.Error()})
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		h.logger.Error("Failed to save file", map[string]interface{}{"error": err.Error()})
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+	fileURL, ok := h.saveUpload(w, r)
+	if !ok {
 		return
 	}
 
@@ -89,8 +61,6 @@ func (h *ComplianceHandler) SubmitKYC(w http.ResponseWriter, r *http.Request) {
 	// Note: For simplicity, we assume one file for Front Image.
 	// In a real scenario, we might handle multiple files or map based on docType.
 	// We'll use the same URL for all image fields for now or just Front.
-	fileURL := "/uploads/kyc/" + filename
-
 	req := &compliance.SubmitKYCRequest{
 		UserID:         userID,
 		DocumentType:   docType,
@@ -118,20 +88,105 @@ func (h *ComplianceHandler) GetKYCStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	docs, err := h.service.GetKYCStatus(r.Context(), userID)
+	status, err := h.service.GetKYCStatus(r.Context(), userID)
 	if err != nil {
 		h.logger.Error("Failed to get KYC status", map[string]interface{}{"error": err.Error()})
 		h.respondError(w, http.StatusInternalServerError, "Failed to get KYC status")
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, docs)
+	h.respondJSON(w, http.StatusOK, status)
+}
+
+// ResubmitDocument replaces a rejected or pending document with a new upload.
+// The earlier attempt stays in the status history.
+func (h *ComplianceHandler) ResubmitDocument(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10MB limit
+		h.respondError(w, http.StatusBadRequest, "File too large or invalid form")
+		return
+	}
+
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	docID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	fileURL, ok := h.saveUpload(w, r)
+	if !ok {
+		return
+	}
+
+	doc, err := h.service.ResubmitDocument(r.Context(), &compliance.ResubmitDocumentRequest{
+		UserID:        userID,
+		DocumentID:    docID,
+		FrontImageURL: fileURL,
+	})
+	switch {
+	case errors.Is(err, compliance.ErrDocumentNotFound):
+		h.respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, compliance.ErrDocumentReplaced), errors.Is(err, compliance.ErrDocumentNotReplaced):
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.logger.Error("Failed to resubmit KYC document", map[string]interface{}{"error": err.Error(), "document_id": docID})
+		h.respondError(w, http.StatusInternalServerError, "Failed to resubmit KYC document")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, doc)
+}
+
+// saveUpload stores the "documents" file of a KYC form under ./uploads/kyc
+// and returns its URL. On failure it has already written the response.
+func (h *ComplianceHandler) saveUpload(w http.ResponseWriter, r *http.Request) (string, bool) {
+	file, handler, err := r.FormFile("documents") // Frontend sends 'documents'
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Missing document file")
+		return "", false
+	}
+	defer file.Close()
+
+	// Ensure upload directory exists
+	uploadDir := "./uploads/kyc"
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		h.logger.Error("Failed to create upload directory", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		return "", false
+	}
+
+	// Generate filename
+	ext := filepath.Ext(handler.Filename)
+	filename := uuid.New().String() + ext
+	filePath := filepath.Join(uploadDir, filename)
+
+	dst, err := os.Create(filePath)
+	if err != nil {
+		h.logger.Error("Failed to create file", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		return "", false
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		h.logger.Error("Failed to save file", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+		return "", false
+	}
+
+	return "/uploads/kyc/" + filename, true
 }
 
 func (h *ComplianceHandler) ListApplications(w http.ResponseWriter, r *http.Request) {
 	// Admin Check
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -167,8 +222,7 @@ func (h *ComplianceHandler) ListApplications(w http.ResponseWriter, r *http.Requ
 
 func (h *ComplianceHandler) ReviewApplication(w http.ResponseWriter, r *http.Request) {
 	// Admin Check
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -193,6 +247,10 @@ func (h *ComplianceHandler) ReviewApplication(w http.ResponseWriter, r *http.Req
 	adminID, _ := middleware.UserIDFromContext(r.Context())
 
 	if err := h.service.ReviewApplication(r.Context(), id, req.Status, req.Reason, adminID); err != nil {
+		if errors.Is(err, compliance.ErrDocumentNotScanned) || errors.Is(err, compliance.ErrSanctionsMatch) || errors.Is(err, compliance.ErrNotScreened) {
+			h.respondError(w, http.StatusConflict, err.Error())
+			return
+		}
 		h.logger.Error("Failed to review kyc application", map[string]interface{}{"error": err.Error(), "user_id": id})
 		h.respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -201,10 +259,135 @@ func (h *ComplianceHandler) ReviewApplication(w http.ResponseWriter, r *http.Req
 	h.respondJSON(w, http.StatusOK, map[string]string{"message": "kyc status updated successfully"})
 }
 
+// RescanDocument scans a KYC document again whose earlier virus scan
+// failed to reach a verdict (admin).
+func (h *ComplianceHandler) RescanDocument(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	docID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	doc, err := h.service.RescanDocument(r.Context(), docID)
+	switch {
+	case errors.Is(err, compliance.ErrDocumentNotFound):
+		h.respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, compliance.ErrDocumentReplaced), errors.Is(err, compliance.ErrScanNotNeeded):
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.logger.Error("Failed to rescan KYC document", map[string]interface{}{"error": err.Error(), "document_id": docID})
+		h.respondError(w, http.StatusInternalServerError, "Failed to rescan KYC document")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, doc)
+}
+
+// GetScreening returns a user's latest sanctions and PEP screening (admin).
+func (h *ComplianceHandler) GetScreening(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	sc, err := h.service.LatestScreening(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to get aml screening", map[string]interface{}{"error": err.Error(), "user_id": userID})
+		h.respondError(w, http.StatusInternalServerError, "Failed to get aml screening")
+		return
+	}
+	if sc == nil {
+		h.respondError(w, http.StatusNotFound, "user has not been screened")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, sc)
+}
+
+// ScreenUser screens a user against the sanctions and PEP lists now
+// (admin).
+func (h *ComplianceHandler) ScreenUser(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	sc, err := h.service.ScreenUser(r.Context(), userID)
+	switch {
+	case errors.Is(err, pkgerrors.ErrUserNotFound):
+		h.respondError(w, http.StatusNotFound, "user not found")
+		return
+	case errors.Is(err, compliance.ErrNotScreened):
+		h.respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		h.logger.Error("Failed to screen user", map[string]interface{}{"error": err.Error(), "user_id": userID})
+		h.respondError(w, http.StatusInternalServerError, "Failed to screen user")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, sc)
+}
+
+// ClearScreening marks a user's open sanctions match a false positive
+// (admin).
+func (h *ComplianceHandler) ClearScreening(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	sc, err := h.service.ClearScreening(r.Context(), userID, req.Reason, adminID)
+	switch {
+	case errors.Is(err, compliance.ErrNothingToClear):
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.logger.Error("Failed to clear aml screening", map[string]interface{}{"error": err.Error(), "user_id": userID})
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, sc)
+}
+
 func (h *ComplianceHandler) GetComplianceReports(w http.ResponseWriter, r *http.Request) {
 	// Admin Check
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/counterparty"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CounterpartiesHandler serves settlement partners, payout providers and
+// correspondent banks to admins.
+type CounterpartiesHandler struct {
+	service *counterparty.Service
+	logger  logger.Logger
+}
+
+func NewCounterpartiesHandler(service *counterparty.Service, log logger.Logger) *CounterpartiesHandler {
+	return &CounterpartiesHandler{service: service, logger: log}
+}
+
+// counterpartyID parses the {id} route variable, answering 400 when it is
+// not a UUID.
+func counterpartyID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid counterparty ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// List returns counterparties, optionally filtered by status and type (admin).
+func (h *CounterpartiesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	q := r.URL.Query()
+	items, err := h.service.List(r.Context(), q.Get("status"), q.Get("type"))
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": len(items)})
+}
+
+// Create adds a counterparty (admin).
+func (h *CounterpartiesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	var req counterparty.CounterpartyRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	c, err := h.service.Create(r.Context(), req, adminID)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, c)
+}
+
+// Get returns a counterparty with its prefunded balances (admin).
+func (h *CounterpartiesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	c, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	balances, err := h.service.Balances(r.Context(), id)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"counterparty": c, "balances": balances})
+}
+
+// Update replaces a counterparty's details (admin).
+func (h *CounterpartiesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	var req counterparty.CounterpartyRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	c, err := h.service.Update(r.Context(), id, req)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+// Delete removes a counterparty that never held prefunding (admin).
+func (h *CounterpartiesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetStatus suspends or reactivates a counterparty (admin).
+func (h *CounterpartiesHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Status domain.CounterpartyStatus `json:"status"`
+		Reason string                    `json:"reason"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	c, err := h.service.SetStatus(r.Context(), id, req.Status, req.Reason)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+// ListDocuments returns a counterparty's documents (admin).
+func (h *CounterpartiesHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	docs, err := h.service.ListDocuments(r.Context(), id)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"documents": docs})
+}
+
+// AddDocument records a contract, licence or compliance document (admin).
+func (h *CounterpartiesHandler) AddDocument(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	var req counterparty.DocumentRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	doc, err := h.service.AddDocument(r.Context(), id, req, adminID)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, doc)
+}
+
+// DeleteDocument removes a counterparty document (admin).
+func (h *CounterpartiesHandler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	docID, err := uuid.Parse(mux.Vars(r)["doc_id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid document ID")
+		return
+	}
+	if err := h.service.DeleteDocument(r.Context(), id, docID); err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPrefunding returns a counterparty's balances and the movements behind
+// them, newest first (admin).
+func (h *CounterpartiesHandler) ListPrefunding(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	balances, err := h.service.Balances(r.Context(), id)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	limit, offset := parsePagination(r)
+	entries, total, err := h.service.ListPrefunding(r.Context(), id, limit, offset)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"balances": balances,
+		"entries":  entries,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// Prefund records funding received from or returned to a counterparty (admin).
+func (h *CounterpartiesHandler) Prefund(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, ok := counterpartyID(w, r)
+	if !ok {
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	var req counterparty.PrefundingRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	entry, err := h.service.Prefund(r.Context(), id, req, adminID)
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, entry)
+}
+
+// Exposure returns the value outstanding with each counterparty against
+// its limit, most utilised first (admin).
+func (h *CounterpartiesHandler) Exposure(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	items, err := h.service.Exposures(r.Context())
+	if err != nil {
+		h.respondCounterpartyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": len(items)})
+}
+
+func (h *CounterpartiesHandler) respondCounterpartyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, counterparty.ErrCounterpartyNotFound), errors.Is(err, counterparty.ErrDocumentNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, counterparty.ErrInvalidCounterparty), errors.Is(err, counterparty.ErrInvalidPrefunding):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, counterparty.ErrCounterpartyExists), errors.Is(err, counterparty.ErrCounterpartyInUse),
+		errors.Is(err, counterparty.ErrInsufficientPrefunding):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Counterparty request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process counterparty request")
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/credit"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// CreditHandler lets customers see their overdraft facilities and admins
+// open, change and report on them.
+type CreditHandler struct {
+	service   *credit.Service
+	validator *validator.Validator
+	logger    logger.Logger
+}
+
+func NewCreditHandler(service *credit.Service, val *validator.Validator, log logger.Logger) *CreditHandler {
+	return &CreditHandler{service: service, validator: val, logger: log}
+}
+
+// creditFacilityResponse adds what the customer owes and may still draw to
+// a facility.
+type creditFacilityResponse struct {
+	*domain.CreditFacility
+	Overdrawn       decimal.Decimal `json:"overdrawn"`
+	AvailableCredit decimal.Decimal `json:"available_credit"`
+}
+
+func creditFacilityResponses(items []domain.CreditFacility) []creditFacilityResponse {
+	out := make([]creditFacilityResponse, len(items))
+	for i := range items {
+		out[i] = creditFacilityView(&items[i])
+	}
+	return out
+}
+
+func creditFacilityView(f *domain.CreditFacility) creditFacilityResponse {
+	return creditFacilityResponse{CreditFacility: f, Overdrawn: f.Overdrawn(), AvailableCredit: f.AvailableCredit()}
+}
+
+// MyFacilities returns the caller's overdraft facilities.
+func (h *CreditHandler) MyFacilities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	items, err := h.service.Facilities(r.Context(), userID)
+	if err != nil {
+		h.respondCreditError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": creditFacilityResponses(items)})
+}
+
+// Open gives a customer an overdraft on one of their wallets (admin).
+func (h *CreditHandler) Open(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req credit.OpenRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	f, err := h.service.Open(r.Context(), &req, &adminID)
+	if err != nil {
+		h.respondCreditError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, creditFacilityView(f))
+}
+
+// List returns facilities, optionally filtered by user_id, status and
+// delinquency (admin).
+func (h *CreditHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	q := r.URL.Query()
+	filter := domain.CreditFacilityFilter{
+		Status:      domain.CreditFacilityStatus(q.Get("status")),
+		Delinquency: domain.CreditDelinquency(q.Get("delinquency")),
+	}
+	if v := q.Get("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		filter.UserID = &id
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.List(r.Context(), filter, limit, offset)
+	if err != nil {
+		h.respondCreditError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  creditFacilityResponses(items),
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Update changes a facility's limit, rate or status (admin).
+func (h *CreditHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid facility ID")
+		return
+	}
+	var req credit.UpdateRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	f, err := h.service.Update(r.Context(), id, &req)
+	if err != nil {
+		h.respondCreditError(w, err)
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	h.logger.Info("Credit facility updated", map[string]interface{}{
+		"event":       "credit_facility_updated",
+		"admin_id":    adminID,
+		"facility_id": f.ID,
+		"status":      f.Status,
+		"limit":       f.Limit.String(),
+		"annual_rate": f.AnnualRate.String(),
+	})
+	respondJSON(w, http.StatusOK, creditFacilityView(f))
+}
+
+// Report sums what is owed on facilities by delinquency and currency
+// (admin).
+func (h *CreditHandler) Report(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	lines, err := h.service.DelinquencyReport(r.Context())
+	if err != nil {
+		h.respondCreditError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": lines})
+}
+
+// Run accrues and collects interest and updates delinquency now, and
+// returns what it did (admin).
+func (h *CreditHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	run, err := h.service.Run(r.Context())
+	if err != nil {
+		h.respondCreditError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
+
+func (h *CreditHandler) respondCreditError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, credit.ErrFacilityNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, credit.ErrInvalidFacility):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, credit.ErrNotEligible):
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, credit.ErrFacilityExists),
+		errors.Is(err, credit.ErrFacilityClosed),
+		errors.Is(err, credit.ErrRunInProgress):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Credit request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
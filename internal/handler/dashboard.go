@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"kyd/internal/dashboard"
+	"kyd/internal/middleware"
+)
+
+// DashboardHandler serves the aggregated admin dashboard and customer home
+// screen.
+type DashboardHandler struct {
+	service *dashboard.Service
+	home    *dashboard.Home
+}
+
+func NewDashboardHandler(service *dashboard.Service) *DashboardHandler {
+	return &DashboardHandler{service: service}
+}
+
+// WithHome enables the customer home endpoint.
+func (h *DashboardHandler) WithHome(home *dashboard.Home) *DashboardHandler {
+	h.home = home
+	return h
+}
+
+// Overview returns every dashboard section in one response. Sections that
+// fail are reported inside the body rather than failing the request, so the
+// status is 200 unless the caller is not an admin. ?refresh=true skips the
+// cache.
+func (h *DashboardHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	fresh := r.URL.Query().Get("refresh") == "true"
+	w.Header().Set("Cache-Control", "private, no-store")
+	respondJSON(w, http.StatusOK, h.service.Overview(r.Context(), fresh))
+}
+
+// Home returns everything the app's home screen shows for the caller in one
+// response. Like Overview, failed sections are reported in the body.
+func (h *DashboardHandler) Home(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if h.home == nil {
+		respondError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	respondJSON(w, http.StatusOK, h.home.Build(r.Context(), userID))
+}
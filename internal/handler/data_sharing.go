@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"kyd/internal/datasharing"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// partnerKeyHeader carries a credit-scoring partner's key on the partner
+// API.
+const partnerKeyHeader = "X-Partner-Key"
+
+// DataSharingHandler serves users' consents to share transaction summaries
+// with credit-scoring partners, the partner API reading them, and the
+// partner registry.
+type DataSharingHandler struct {
+	service   *datasharing.Service
+	validator *validator.Validator
+	logger    logger.Logger
+}
+
+func NewDataSharingHandler(service *datasharing.Service, val *validator.Validator, log logger.Logger) *DataSharingHandler {
+	return &DataSharingHandler{service: service, validator: val, logger: log}
+}
+
+// ListPartners returns the partners a user may share data with.
+func (h *DataSharingHandler) ListPartners(w http.ResponseWriter, r *http.Request) {
+	partners, err := h.service.Partners(r.Context(), true)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": partners})
+}
+
+// GrantConsent lets a partner read the requested scopes of the caller's
+// transaction summary.
+func (h *DataSharingHandler) GrantConsent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req datasharing.GrantRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	consent, err := h.service.Grant(r.Context(), userID, &req)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	h.logger.Info("Data sharing consent granted", map[string]interface{}{
+		"event":      "data_sharing_consent_granted",
+		"user_id":    userID,
+		"consent_id": consent.ID,
+		"partner_id": consent.PartnerID,
+		"scopes":     []string(consent.Scopes),
+	})
+	respondJSON(w, http.StatusCreated, h.consentResponse(consent))
+}
+
+// ListConsents returns the caller's consents, newest first, with where each
+// stands.
+func (h *DataSharingHandler) ListConsents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	consents, err := h.service.Consents(r.Context(), userID)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	items := make([]consentResponse, len(consents))
+	for i := range consents {
+		items[i] = h.consentResponse(&consents[i])
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// RevokeConsent stops a partner reading the caller's data at once.
+func (h *DataSharingHandler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid consent ID")
+		return
+	}
+	if err := h.service.Revoke(r.Context(), userID, id); err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	h.logger.Info("Data sharing consent revoked", map[string]interface{}{
+		"event":      "data_sharing_consent_revoked",
+		"user_id":    userID,
+		"consent_id": id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AccessLog returns partners' reads of the caller's data, newest first,
+// for one consent when consent_id is given.
+func (h *DataSharingHandler) AccessLog(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var consentID *uuid.UUID
+	if v := r.URL.Query().Get("consent_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid consent ID")
+			return
+		}
+		consentID = &id
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.AccessLog(r.Context(), userID, consentID, limit, offset)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// PartnerConsents returns the consents in force granted to the calling
+// partner, filtered by the partner's own reference when given.
+func (h *DataSharingHandler) PartnerConsents(w http.ResponseWriter, r *http.Request) {
+	partner, ok := h.partner(w, r)
+	if !ok {
+		return
+	}
+	consents, err := h.service.PartnerConsents(r.Context(), partner, r.URL.Query().Get("reference"))
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	type partnerConsent struct {
+		ID               uuid.UUID `json:"id"`
+		Scopes           []string  `json:"scopes"`
+		LookbackMonths   int       `json:"lookback_months"`
+		PartnerReference string    `json:"partner_reference,omitempty"`
+		GrantedAt        time.Time `json:"granted_at"`
+		ExpiresAt        time.Time `json:"expires_at"`
+	}
+	items := make([]partnerConsent, len(consents))
+	for i, c := range consents {
+		items[i] = partnerConsent{
+			ID:               c.ID,
+			Scopes:           []string(c.Scopes),
+			LookbackMonths:   c.LookbackMonths,
+			PartnerReference: c.PartnerReference,
+			GrantedAt:        c.GrantedAt,
+			ExpiresAt:        c.ExpiresAt,
+		}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// PartnerSummary returns the summary a consent lets the calling partner
+// read. The read is logged for the user to see.
+func (h *DataSharingHandler) PartnerSummary(w http.ResponseWriter, r *http.Request) {
+	partner, ok := h.partner(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid consent ID")
+		return
+	}
+	summary, err := h.service.Summary(r.Context(), partner, id, requestIP(r))
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, summary)
+}
+
+// CreatePartner registers a credit-scoring partner (admin). Its key is in
+// the response and cannot be shown again.
+func (h *DataSharingHandler) CreatePartner(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req struct {
+		Name        string `json:"name" validate:"required,max=100"`
+		Description string `json:"description" validate:"max=1000"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	var createdBy *uuid.UUID
+	if id, ok := middleware.UserIDFromContext(r.Context()); ok {
+		createdBy = &id
+	}
+	partner, key, err := h.service.CreatePartner(r.Context(), req.Name, req.Description, createdBy)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	h.logger.Info("Data partner created", map[string]interface{}{
+		"event":      "data_partner_created",
+		"partner_id": partner.ID,
+		"created_by": createdBy,
+	})
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"partner": partner,
+		"key":     key,
+	})
+}
+
+// ListPartnersAdmin returns every partner, suspended ones included (admin).
+func (h *DataSharingHandler) ListPartnersAdmin(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	partners, err := h.service.Partners(r.Context(), false)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": partners})
+}
+
+// SetPartnerStatus suspends or reinstates a partner (admin). A suspended
+// partner's key stops working at once.
+func (h *DataSharingHandler) SetPartnerStatus(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid partner ID")
+		return
+	}
+	var req struct {
+		Active *bool `json:"active" validate:"required"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	partner, err := h.service.SetPartnerActive(r.Context(), id, *req.Active)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return
+	}
+	actor, _ := middleware.UserIDFromContext(r.Context())
+	h.logger.Info("Data partner status changed", map[string]interface{}{
+		"event":      "data_partner_status_changed",
+		"partner_id": id,
+		"active":     partner.Active,
+		"actor_id":   actor,
+	})
+	respondJSON(w, http.StatusOK, partner)
+}
+
+// consentResponse adds where a consent stands to it.
+type consentResponse struct {
+	*domain.DataSharingConsent
+	Status domain.DataSharingConsentStatus `json:"status"`
+}
+
+func (h *DataSharingHandler) consentResponse(c *domain.DataSharingConsent) consentResponse {
+	return consentResponse{DataSharingConsent: c, Status: c.Status(time.Now())}
+}
+
+// partner returns the partner the request's key belongs to, having
+// responded if there is none.
+func (h *DataSharingHandler) partner(w http.ResponseWriter, r *http.Request) (*domain.DataPartner, bool) {
+	key := r.Header.Get(partnerKeyHeader)
+	if key == "" {
+		respondError(w, http.StatusUnauthorized, "Missing partner key")
+		return nil, false
+	}
+	partner, err := h.service.Authenticate(r.Context(), key)
+	if err != nil {
+		h.respondDataSharingError(w, err)
+		return nil, false
+	}
+	return partner, true
+}
+
+func (h *DataSharingHandler) respondDataSharingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, datasharing.ErrInvalidPartnerKey):
+		respondError(w, http.StatusUnauthorized, "Invalid partner key")
+	case errors.Is(err, datasharing.ErrConsentInactive):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, datasharing.ErrConsentNotFound):
+		respondError(w, http.StatusNotFound, "Consent not found")
+	case errors.Is(err, datasharing.ErrPartnerNotFound):
+		respondError(w, http.StatusNotFound, "Partner not found")
+	case errors.Is(err, datasharing.ErrInvalidConsent), errors.Is(err, datasharing.ErrInvalidPartner):
+		respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("Data sharing request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// requestIP is the client address, preferring the one the gateway
+// forwarded.
+func requestIP(r *http.Request) string {
+	ip := r.Header.Get("X-Forwarded-For")
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"kyd/internal/developer"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DeveloperHandler serves the developer portal of merchant accounts: usage
+// and failures of their API keys, deliveries to their webhook endpoints,
+// their API environments and the API's reference metadata.
+type DeveloperHandler struct {
+	service *developer.Service
+	baseURL string
+	logger  logger.Logger
+}
+
+// NewDeveloperHandler serves the portal; baseURL is where the API is
+// served, when known.
+func NewDeveloperHandler(service *developer.Service, baseURL string, log logger.Logger) *DeveloperHandler {
+	return &DeveloperHandler{service: service, baseURL: baseURL, logger: log}
+}
+
+func (h *DeveloperHandler) merchant(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	return requireMerchant(w, r, "The developer portal is available to merchant accounts")
+}
+
+// parseDays reads the period statistics cover; the service applies the default
+// and the bound.
+func parseDays(r *http.Request) int {
+	n, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	return n
+}
+
+// Keys returns the caller's API keys with their usage over ?days=.
+func (h *DeveloperHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	keys, err := h.service.Keys(r.Context(), owner, parseDays(r))
+	if err != nil {
+		h.fail(w, "Failed to list API keys", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": keys, "total": len(keys)})
+}
+
+// KeyUsage returns one of the caller's keys' usage per day over ?days=.
+func (h *DeveloperHandler) KeyUsage(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+	usage, err := h.service.KeyUsage(r.Context(), owner, id, parseDays(r))
+	if errors.Is(err, developer.ErrKeyNotFound) {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		h.fail(w, "Failed to load API key usage", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, usage)
+}
+
+// KeyErrors returns the latest failed calls made with one of the caller's
+// keys.
+func (h *DeveloperHandler) KeyErrors(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	samples, err := h.service.KeyErrors(r.Context(), owner, id, limit)
+	if errors.Is(err, developer.ErrKeyNotFound) {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		h.fail(w, "Failed to list API key errors", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": samples, "total": len(samples)})
+}
+
+// Webhooks sums the deliveries to each of the caller's endpoints over
+// ?days=.
+func (h *DeveloperHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	summaries, err := h.service.WebhookSummary(r.Context(), owner, parseDays(r))
+	if err != nil {
+		h.fail(w, "Failed to summarize webhook deliveries", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": summaries, "total": len(summaries)})
+}
+
+// Environments returns which API environments the caller has switched on.
+func (h *DeveloperHandler) Environments(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	env, err := h.service.Environments(r.Context(), owner)
+	if err != nil {
+		h.fail(w, "Failed to load environments", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, env)
+}
+
+// SetEnvironments switches the caller's API environments on or off.
+func (h *DeveloperHandler) SetEnvironments(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	var req developer.EnvironmentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	env, err := h.service.SetEnvironments(r.Context(), owner, req)
+	if err != nil {
+		h.fail(w, "Failed to update environments", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, env)
+}
+
+// Docs returns the metadata the portal renders its API reference from.
+func (h *DeveloperHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	docs, err := h.service.Docs(r.Context(), owner, h.baseURL)
+	if err != nil {
+		h.fail(w, "Failed to load API docs", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, docs)
+}
+
+func (h *DeveloperHandler) fail(w http.ResponseWriter, msg string, err error) {
+	h.logger.Error(msg, map[string]interface{}{"error": err.Error()})
+	respondError(w, http.StatusInternalServerError, "Failed to process request")
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/auth"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// deviceResponse marks the device the request was made from.
+type deviceResponse struct {
+	domain.UserDevice
+	Current bool `json:"current"`
+}
+
+// ListDevices returns the devices the authenticated user has signed in from.
+func (h *AuthHandler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	devices, err := h.service.Devices(r.Context(), userID)
+	if err != nil {
+		h.respondDeviceError(w, err)
+		return
+	}
+	current, _ := middleware.DeviceIDFromContext(r.Context())
+	items := make([]deviceResponse, len(devices))
+	for i, d := range devices {
+		items[i] = deviceResponse{UserDevice: d, Current: d.ID == current}
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+type renameDeviceRequest struct {
+	Name string `json:"name"`
+}
+
+// RenameDevice names one of the authenticated user's devices.
+func (h *AuthHandler) RenameDevice(w http.ResponseWriter, r *http.Request) {
+	userID, deviceID, ok := h.deviceRequest(w, r)
+	if !ok {
+		return
+	}
+	var req renameDeviceRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	device, err := h.service.RenameDevice(r.Context(), userID, deviceID, req.Name)
+	if err != nil {
+		h.respondDeviceError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, device)
+}
+
+type deviceTrustCodeRequest struct {
+	Method domain.MFAMethod `json:"method" validate:"required"`
+}
+
+// SendDeviceTrustCode sends an SMS or email code for trusting a device.
+func (h *AuthHandler) SendDeviceTrustCode(w http.ResponseWriter, r *http.Request) {
+	userID, deviceID, ok := h.deviceRequest(w, r)
+	if !ok {
+		return
+	}
+	var req deviceTrustCodeRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		h.respondValidationErrors(w, errs)
+		return
+	}
+	if err := h.service.SendDeviceTrustCode(r.Context(), userID, deviceID, req.Method); err != nil {
+		h.respondDeviceError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Code sent"})
+}
+
+type trustDeviceRequest struct {
+	Method domain.MFAMethod `json:"method" validate:"required"`
+	Code   string           `json:"code" validate:"required"`
+}
+
+// TrustDevice trusts one of the authenticated user's devices once they
+// prove a second factor, letting it move money.
+func (h *AuthHandler) TrustDevice(w http.ResponseWriter, r *http.Request) {
+	userID, deviceID, ok := h.deviceRequest(w, r)
+	if !ok {
+		return
+	}
+	var req trustDeviceRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		h.respondValidationErrors(w, errs)
+		return
+	}
+	device, err := h.service.TrustDevice(r.Context(), userID, deviceID, req.Method, req.Code)
+	if err != nil {
+		h.respondDeviceError(w, err)
+		return
+	}
+	h.logger.Info("Device trusted", map[string]interface{}{
+		"event":     "device_trusted",
+		"user_id":   userID,
+		"device_id": deviceID,
+		"method":    req.Method,
+	})
+	h.respondJSON(w, http.StatusOK, device)
+}
+
+// UntrustDevice stops one of the authenticated user's devices from moving
+// money.
+func (h *AuthHandler) UntrustDevice(w http.ResponseWriter, r *http.Request) {
+	userID, deviceID, ok := h.deviceRequest(w, r)
+	if !ok {
+		return
+	}
+	device, err := h.service.UntrustDevice(r.Context(), userID, deviceID)
+	if err != nil {
+		h.respondDeviceError(w, err)
+		return
+	}
+	h.logger.Info("Device untrusted", map[string]interface{}{
+		"event":     "device_untrusted",
+		"user_id":   userID,
+		"device_id": deviceID,
+	})
+	h.respondJSON(w, http.StatusOK, device)
+}
+
+// RevokeDevice forgets one of the authenticated user's devices and signs out
+// its sessions.
+func (h *AuthHandler) RevokeDevice(w http.ResponseWriter, r *http.Request) {
+	userID, deviceID, ok := h.deviceRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.RevokeDevice(r.Context(), userID, deviceID); err != nil {
+		h.respondDeviceError(w, err)
+		return
+	}
+	h.logger.Info("Device revoked", map[string]interface{}{
+		"event":     "device_revoked",
+		"user_id":   userID,
+		"device_id": deviceID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deviceRequest returns the authenticated user and the device in the path,
+// having responded if either is missing.
+func (h *AuthHandler) deviceRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, uuid.Nil, false
+	}
+	deviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid device ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, deviceID, true
+}
+
+func (h *AuthHandler) respondDeviceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrDeviceNotFound):
+		h.respondError(w, http.StatusNotFound, "Device not found")
+	case errors.Is(err, auth.ErrInvalidDeviceName):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, auth.ErrDevicesNotConfigured):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	default:
+		h.respondMFAError(w, err)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/dormancy"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DormancyHandler lets customers see and leave dormancy, and admins follow
+// the workflow, reactivate escheated accounts and report escheatments.
+type DormancyHandler struct {
+	service *dormancy.Service
+	logger  logger.Logger
+}
+
+func NewDormancyHandler(service *dormancy.Service, log logger.Logger) *DormancyHandler {
+	return &DormancyHandler{service: service, logger: log}
+}
+
+// Status returns where the caller's account is in the dormancy workflow and
+// any escheated balances held for them.
+func (h *DormancyHandler) Status(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	account, held, err := h.service.Status(r.Context(), userID)
+	if err != nil {
+		h.respondDormancyError(w, err)
+		return
+	}
+	if account == nil {
+		respondJSON(w, http.StatusOK, map[string]interface{}{"status": "active", "escheatments": []domain.Escheatment{}})
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"status": account.Status, "account": account, "escheatments": held})
+}
+
+// RequestReactivation reopens the caller's dormant account, or asks an
+// admin to return their escheated balances.
+func (h *DormancyHandler) RequestReactivation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	account, err := h.service.RequestReactivation(r.Context(), userID)
+	if err != nil {
+		h.respondDormancyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, account)
+}
+
+// ListAccounts returns accounts in the workflow, optionally filtered by
+// status (admin).
+func (h *DormancyHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	status := domain.DormancyStatus(r.URL.Query().Get("status"))
+	items, total, err := h.service.ListAccounts(r.Context(), status, limit, offset)
+	if err != nil {
+		h.respondDormancyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// Reactivate returns a customer's escheated balances and reopens their
+// wallets (admin).
+func (h *DormancyHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	account, err := h.service.Reactivate(r.Context(), userID, adminID)
+	if err != nil {
+		h.respondDormancyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, account)
+}
+
+// Escheatments reports the escheatments made between the from and to dates
+// (YYYY-MM-DD, inclusive; the last 30 days by default) per country and
+// currency (admin).
+func (h *DormancyHandler) Escheatments(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	from, to, err := reportRange(r, 30)
+	if err != nil || !from.Before(to) {
+		respondError(w, http.StatusBadRequest, "Invalid date range")
+		return
+	}
+	report, err := h.service.Report(r.Context(), from, to)
+	if err != nil {
+		h.respondDormancyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// Run runs the dormancy workflow now and returns what it did (admin).
+func (h *DormancyHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	run, err := h.service.Run(r.Context())
+	if err != nil {
+		h.respondDormancyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
+
+func (h *DormancyHandler) respondDormancyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dormancy.ErrNotDormant):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, dormancy.ErrRunInProgress):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Dormancy request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
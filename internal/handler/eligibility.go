@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"kyd/internal/auth"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// EligibilityHandler manages the per-country registration eligibility
+// rules and appeals against registrations they refused.
+type EligibilityHandler struct {
+	service   *auth.Service
+	validator *validator.Validator
+	logger    logger.Logger
+}
+
+func NewEligibilityHandler(service *auth.Service, val *validator.Validator, log logger.Logger) *EligibilityHandler {
+	return &EligibilityHandler{service: service, validator: val, logger: log}
+}
+
+func asIneligible(err error) (*auth.IneligibleError, bool) {
+	var ineligible *auth.IneligibleError
+	ok := errors.As(err, &ineligible)
+	return ineligible, ok
+}
+
+// respondIneligible reports a registration refused by the eligibility
+// rules, with the reasons and the rejection to appeal.
+func respondIneligible(w http.ResponseWriter, err *auth.IneligibleError) {
+	respondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":        "Not eligible to register",
+		"code":         "registration_ineligible",
+		"rejection_id": err.RejectionID,
+		"reasons":      err.Reasons,
+	})
+}
+
+// Appeal asks for a refused registration to be reviewed. Public: the
+// applicant has no account; the email must match the registration.
+func (h *EligibilityHandler) Appeal(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RejectionID string `json:"rejection_id" validate:"required,uuid"`
+		Email       string `json:"email" validate:"required,email"`
+		Statement   string `json:"statement" validate:"required,min=10,max=2000"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	rejection, err := h.service.AppealRejection(r.Context(), uuid.MustParse(req.RejectionID), req.Email, strings.TrimSpace(req.Statement))
+	if err != nil {
+		h.respondEligibilityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"rejection_id": rejection.ID,
+		"status":       rejection.Status,
+	})
+}
+
+// ListRules returns the per-country rules and the default for the rest
+// (admin).
+func (h *EligibilityHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	rules, def, err := h.service.ListEligibilityRules(r.Context())
+	if err != nil {
+		h.respondEligibilityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": rules, "default": def})
+}
+
+// SetRule creates or replaces the rule of the country in the path (admin).
+func (h *EligibilityHandler) SetRule(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok || !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var rule domain.EligibilityRule
+	if err := decodeStrict(w, r, &rule); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	rule.CountryCode = mux.Vars(r)["country"]
+	if err := h.service.SetEligibilityRule(r.Context(), &rule, adminID); err != nil {
+		h.respondEligibilityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// DeleteRule returns the country in the path to the default rule (admin).
+func (h *EligibilityHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	if err := h.service.DeleteEligibilityRule(r.Context(), mux.Vars(r)["country"]); err != nil {
+		h.respondEligibilityError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRejections returns refused registrations, optionally filtered by
+// status, e.g. appealed for the review queue (admin).
+func (h *EligibilityHandler) ListRejections(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	status := domain.EligibilityRejectionStatus(r.URL.Query().Get("status"))
+	items, total, err := h.service.ListEligibilityRejections(r.Context(), status, limit, offset)
+	if err != nil {
+		h.respondEligibilityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// DecideAppeal approves or denies an appeal (admin).
+func (h *EligibilityHandler) DecideAppeal(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok || !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid rejection ID")
+		return
+	}
+	var req struct {
+		Decision string `json:"decision" validate:"required,oneof=approve deny"`
+		Note     string `json:"note" validate:"max=2000"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	rejection, err := h.service.DecideAppeal(r.Context(), id, req.Decision == "approve", strings.TrimSpace(req.Note), adminID)
+	if err != nil {
+		h.respondEligibilityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, rejection)
+}
+
+func (h *EligibilityHandler) respondEligibilityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidRule):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, auth.ErrRejectionNotFound):
+		respondError(w, http.StatusNotFound, "Rejection not found")
+	case errors.Is(err, auth.ErrAppealNotAllowed), errors.Is(err, auth.ErrAppealNotPending):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Eligibility request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
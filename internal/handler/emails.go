@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"kyd/internal/auth"
+	"kyd/internal/middleware"
+	"kyd/pkg/mailer"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type listEmailsResponse struct {
+	Emails []*mailer.Message `json:"emails"`
+	Total  int               `json:"total"`
+	Limit  int               `json:"limit"`
+	Offset int               `json:"offset"`
+}
+
+// ListEmails lists tracked outgoing email for admins, optionally filtered
+// by status and to critical kinds only.
+func (h *UsersHandler) ListEmails(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	qp := r.URL.Query()
+	f := mailer.MessageFilter{Limit: 50}
+	if v := qp.Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			f.Limit = n
+		}
+	}
+	if v := qp.Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			f.Offset = n
+		}
+	}
+	switch status := mailer.DeliveryStatus(qp.Get("status")); status {
+	case "", mailer.StatusQueued, mailer.StatusSent, mailer.StatusBounced, mailer.StatusFailed, mailer.StatusSuppressed:
+		f.Status = status
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	f.CriticalOnly, _ = strconv.ParseBool(qp.Get("critical"))
+
+	msgs, total, err := h.service.ListEmails(r.Context(), f)
+	if err != nil {
+		h.logger.Error("Admin list emails failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to list emails")
+		return
+	}
+	if !seesUnmaskedData(r) {
+		msgs = maskEmailMessages(msgs)
+	}
+	respondJSON(w, http.StatusOK, listEmailsResponse{Emails: msgs, Total: total, Limit: f.Limit, Offset: f.Offset})
+}
+
+// ResendEmail resends a failed, bounced or suppressed message.
+func (h *UsersHandler) ResendEmail(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid email ID")
+		return
+	}
+	var body struct {
+		LiftSuppression bool `json:"lift_suppression"`
+	}
+	if r.Body != nil && r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	err = h.service.ResendEmail(r.Context(), id, body.LiftSuppression)
+	switch {
+	case err == nil:
+	case errors.Is(err, mailer.ErrMessageNotFound):
+		respondError(w, http.StatusNotFound, "Email not found")
+		return
+	case errors.Is(err, auth.ErrEmailNotFailed), errors.Is(err, auth.ErrEmailAlreadyVerified), errors.Is(err, auth.ErrInvitationAccepted), errors.Is(err, mailer.ErrSuppressed):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	default:
+		h.logger.Error("Admin resend email failed", map[string]interface{}{"email_id": id.String(), "error": err.Error()})
+		respondError(w, http.StatusBadGateway, "Failed to resend email")
+		return
+	}
+
+	actorID, _ := middleware.UserIDFromContext(r.Context())
+	h.logger.Info("Email resent", map[string]interface{}{
+		"email_id":         id.String(),
+		"resent_by":        actorID.String(),
+		"lift_suppression": body.LiftSuppression,
+	})
+	respondJSON(w, http.StatusAccepted, map[string]string{"status": "resent", "email_id": id.String()})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateEscrow reserves funds in the caller's wallet for a receiver until
+// the caller releases them, refunds them, or the escrow expires.
+func (h *PaymentHandler) CreateEscrow(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req payment.EscrowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.SenderID = userID
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		h.respondValidationErrors(w, errs)
+		return
+	}
+	resp, err := h.service.CreateEscrow(r.Context(), &req)
+	if err != nil {
+		h.respondEscrowError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, resp)
+}
+
+// ListEscrows returns the escrows the caller sent or receives.
+func (h *PaymentHandler) ListEscrows(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	limit, offset := parsePagination(r)
+	escrows, total, err := h.service.ListEscrows(r.Context(), userID, limit, offset)
+	if err != nil {
+		h.respondEscrowError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"escrows": escrows,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// ReleaseEscrow pays an escrow out to its receiver. Only the sender may.
+func (h *PaymentHandler) ReleaseEscrow(w http.ResponseWriter, r *http.Request) {
+	h.settleEscrow(w, r, h.service.ReleaseEscrow, "Escrow released")
+}
+
+// RefundEscrow returns an escrow's funds to the sender. The sender may
+// refund at any time, the receiver only once the escrow has expired.
+func (h *PaymentHandler) RefundEscrow(w http.ResponseWriter, r *http.Request) {
+	h.settleEscrow(w, r, h.service.RefundEscrow, "Escrow refunded")
+}
+
+func (h *PaymentHandler) settleEscrow(w http.ResponseWriter, r *http.Request, settle func(ctx context.Context, txID, userID uuid.UUID) error, message string) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid escrow ID")
+		return
+	}
+	if err := settle(r.Context(), id, userID); err != nil {
+		h.respondEscrowError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+func (h *PaymentHandler) respondEscrowError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pkgerrors.ErrStepUpLocked):
+		h.respondError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, pkgerrors.ErrMovementFrozen):
+		h.respondError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, pkgerrors.ErrJurisdictionRestricted):
+		h.respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, payment.ErrInvalidEscrow), errors.Is(err, payment.ErrEscrowRefused),
+		errors.Is(err, pkgerrors.ErrInsufficientBalance):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, payment.ErrEscrowForbidden):
+		h.respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, payment.ErrEscrowNotFound), errors.Is(err, pkgerrors.ErrTransactionNotFound):
+		h.respondError(w, http.StatusNotFound, "Escrow not found")
+	case errors.Is(err, payment.ErrEscrowNotHeld):
+		h.respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, payment.ErrEscrowsDisabled):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	default:
+		h.logger.Error("Escrow request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process escrow")
+	}
+}
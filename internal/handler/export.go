@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/export"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type ExportHandler struct {
+	service *export.Service
+	logger  logger.Logger
+}
+
+func NewExportHandler(service *export.Service, log logger.Logger) *ExportHandler {
+	return &ExportHandler{service: service, logger: log}
+}
+
+func (h *ExportHandler) respondExportError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, export.ErrUnknownKind), errors.Is(err, export.ErrInvalidFilters):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, export.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Export not found")
+	case errors.Is(err, export.ErrInvalidLink):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, export.ErrNotReady):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Failed to "+action+" export", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to "+action+" export")
+	}
+}
+
+type exportJobResponse struct {
+	*domain.ExportJob
+	Progress          float64 `json:"progress"`
+	DownloadURL       string  `json:"download_url,omitempty"`
+	DownloadExpiresAt string  `json:"download_expires_at,omitempty"`
+}
+
+func (h *ExportHandler) jobResponse(j *domain.ExportJob) exportJobResponse {
+	return newExportJobResponse(h.service, j)
+}
+
+func newExportJobResponse(service *export.Service, j *domain.ExportJob) exportJobResponse {
+	resp := exportJobResponse{ExportJob: j, Progress: j.Progress()}
+	if u, expires, err := service.DownloadURL(j); err == nil {
+		resp.DownloadURL = u
+		resp.DownloadExpiresAt = expires.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// Create queues an export and returns immediately; poll Get for progress.
+func (h *ExportHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	actorID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req struct {
+		Kind    domain.ExportKind    `json:"kind"`
+		Filters domain.ExportFilters `json:"filters"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	j, err := h.service.Submit(r.Context(), req.Kind, req.Filters, actorID)
+	if err != nil {
+		h.respondExportError(w, err, "create")
+		return
+	}
+	w.Header().Set("Location", "/api/v1/admin/exports/"+j.ID.String())
+	respondJSON(w, http.StatusAccepted, h.jobResponse(j))
+}
+
+func (h *ExportHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+
+	jobs, total, err := h.service.List(r.Context(), limit, offset)
+	if err != nil {
+		h.respondExportError(w, err, "list")
+		return
+	}
+	items := make([]exportJobResponse, 0, len(jobs))
+	for i := range jobs {
+		items = append(items, h.jobResponse(&jobs[i]))
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Get reports progress and, once complete, a signed download link.
+func (h *ExportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid export ID")
+		return
+	}
+	j, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondExportError(w, err, "get")
+		return
+	}
+	respondJSON(w, http.StatusOK, h.jobResponse(j))
+}
+
+// Download streams an export file. It is authorised by the link signature
+// rather than a session, and supports Range requests so interrupted
+// downloads can resume.
+func (h *ExportHandler) Download(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid export ID")
+		return
+	}
+	q := r.URL.Query()
+	f, j, err := h.service.Open(r.Context(), id, q.Get("expires"), q.Get("signature"))
+	if err != nil {
+		h.respondExportError(w, err, "download")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName(j)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	var modTime time.Time
+	if j.CompletedAt != nil {
+		modTime = *j.CompletedAt
+	}
+	http.ServeContent(w, r, export.FileName(j), modTime, f)
+}
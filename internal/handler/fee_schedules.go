@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"kyd/internal/fees"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// FeeScheduleHandler serves the fee schedules payments are priced by.
+type FeeScheduleHandler struct {
+	service *fees.Service
+	logger  logger.Logger
+}
+
+func NewFeeScheduleHandler(service *fees.Service, log logger.Logger) *FeeScheduleHandler {
+	return &FeeScheduleHandler{service: service, logger: log}
+}
+
+// List returns the schedules in effect or yet to take effect, and ended
+// ones too with ?all=true (admin).
+func (h *FeeScheduleHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	schedules, err := h.service.List(r.Context(), r.URL.Query().Get("all") == "true")
+	if err != nil {
+		h.respondScheduleError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"fee_schedules": schedules})
+}
+
+// Create saves a fee schedule (admin).
+func (h *FeeScheduleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	var req fees.ScheduleRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	f, err := h.service.Create(r.Context(), req, adminID)
+	if err != nil {
+		h.respondScheduleError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, f)
+}
+
+// Get returns a fee schedule (admin).
+func (h *FeeScheduleHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid fee schedule ID")
+		return
+	}
+	f, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondScheduleError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, f)
+}
+
+// Update replaces a fee schedule that has not yet taken effect (admin).
+func (h *FeeScheduleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid fee schedule ID")
+		return
+	}
+	var req fees.ScheduleRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	f, err := h.service.Update(r.Context(), id, req)
+	if err != nil {
+		h.respondScheduleError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, f)
+}
+
+// End stops a fee schedule applying from effective_to, or now when the
+// body is empty (admin).
+func (h *FeeScheduleHandler) End(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid fee schedule ID")
+		return
+	}
+	var req struct {
+		EffectiveTo *time.Time `json:"effective_to"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeStrict(w, r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	f, err := h.service.End(r.Context(), id, req.EffectiveTo)
+	if err != nil {
+		h.respondScheduleError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, f)
+}
+
+// Delete removes a fee schedule that has not yet taken effect (admin).
+func (h *FeeScheduleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid fee schedule ID")
+		return
+	}
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.respondScheduleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *FeeScheduleHandler) respondScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fees.ErrScheduleNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, fees.ErrInvalidSchedule):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, fees.ErrScheduleInEffect):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Fee schedule request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process fee schedule request")
+	}
+}
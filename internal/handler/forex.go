@@ -4,6 +4,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
@@ -12,9 +13,11 @@ import (
 
 	"kyd/internal/domain"
 	"kyd/internal/forex"
+	pkgerrors "kyd/pkg/errors"
 	"kyd/pkg/logger"
 	"kyd/pkg/validator"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 )
@@ -177,6 +180,84 @@ func (h *ForexHandler) Calculate(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, result)
 }
 
+// ProviderStatus reports the health of each rate provider as seen by this
+// instance: circuit state, recent error rate and rejected rates (admin).
+func (h *ForexHandler) ProviderStatus(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"providers": h.service.ProviderStatus()})
+}
+
+// GetOHLC returns a pair's rate history as open/high/low/close bars for
+// charts: from, to, resolution (minute, hour or day) and optional RFC 3339
+// start and end.
+func (h *ForexHandler) GetOHLC(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := forex.SeriesRequest{
+		From:       domain.Currency(strings.ToUpper(strings.TrimSpace(q.Get("from")))),
+		To:         domain.Currency(strings.ToUpper(strings.TrimSpace(q.Get("to")))),
+		Resolution: domain.RateResolution(q.Get("resolution")),
+	}
+	for _, p := range []struct {
+		name string
+		into *time.Time
+	}{{"start", &req.Start}, {"end", &req.End}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				h.respondError(w, http.StatusBadRequest, "invalid "+p.name+" timestamp")
+				return
+			}
+			*p.into = t
+		}
+	}
+
+	series, err := h.service.Series(r.Context(), req)
+	if err != nil {
+		h.respondHistoryError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, series)
+}
+
+// TransactionRate returns the rate a transaction was converted at next to
+// the market rate of the time, for audit (admin).
+func (h *ForexHandler) TransactionRate(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	audit, err := h.service.TransactionRate(r.Context(), id)
+	if err != nil {
+		h.respondHistoryError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, audit)
+}
+
+func (h *ForexHandler) respondHistoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, forex.ErrInvalidSeries):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pkgerrors.ErrTransactionNotFound):
+		h.respondError(w, http.StatusNotFound, "Transaction not found")
+	case errors.Is(err, forex.ErrNotConverted):
+		h.respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, forex.ErrHistoryDisabled):
+		h.respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		h.logger.Error("Rate history request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
+
 // WebSocketHandler provides real-time FX rates.
 func (h *ForexHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/freeze"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// FreezeHandler lets admins propose, approve and lift money movement
+// freezes, and serves the public status page.
+type FreezeHandler struct {
+	service *freeze.Service
+	logger  logger.Logger
+}
+
+func NewFreezeHandler(service *freeze.Service, log logger.Logger) *FreezeHandler {
+	return &FreezeHandler{service: service, logger: log}
+}
+
+// Status is the public status page: the money movements currently paused.
+func (h *FreezeHandler) Status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=5")
+	respondJSON(w, http.StatusOK, h.service.Status(r.Context()))
+}
+
+// List returns freezes, optionally filtered by status (admin).
+func (h *FreezeHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	status := domain.FreezeStatus(r.URL.Query().Get("status"))
+	items, total, err := h.service.List(r.Context(), status, limit, offset)
+	if err != nil {
+		h.respondFreezeError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// Get returns a freeze with its audit trail (admin).
+func (h *FreezeHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid freeze ID")
+		return
+	}
+	f, events, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondFreezeError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"freeze": f, "events": events})
+}
+
+// Request proposes a freeze for another admin to approve (admin).
+func (h *FreezeHandler) Request(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req freeze.Request
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	f, err := h.service.Request(r.Context(), req, adminID)
+	if err != nil {
+		h.respondFreezeError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, f)
+}
+
+// RequestLift proposes lifting an active freeze (admin).
+func (h *FreezeHandler) RequestLift(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.service.RequestLift)
+}
+
+// Approve enforces a proposed freeze, or lifts one whose lift was proposed,
+// on behalf of a second admin (admin).
+func (h *FreezeHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.service.Approve)
+}
+
+// Reject turns down a proposed freeze or lift (admin).
+func (h *FreezeHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.service.Reject)
+}
+
+// review runs one maker-checker step on the freeze in the path, with the
+// optional note from the body.
+func (h *FreezeHandler) review(w http.ResponseWriter, r *http.Request, step func(ctx context.Context, id, adminID uuid.UUID, note string) (*domain.MovementFreeze, error)) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid freeze ID")
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeStrict(w, r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	f, err := step(r.Context(), id, adminID, req.Note)
+	if err != nil {
+		h.respondFreezeError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, f)
+}
+
+func (h *FreezeHandler) respondFreezeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, freeze.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, freeze.ErrInvalidFreeze):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, freeze.ErrSelfApproval):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, freeze.ErrDuplicate), errors.Is(err, freeze.ErrNothingToDo),
+		errors.Is(err, freeze.ErrNotActive), errors.Is(err, freeze.ErrConflict):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Freeze request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/funding"
+	"kyd/internal/middleware"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxCallbackBytes bounds a provider callback body.
+const maxCallbackBytes = 1 << 20
+
+// FundingHandler starts wallet top-ups and withdrawals and takes the
+// payment providers' callbacks that complete them.
+type FundingHandler struct {
+	service *funding.Service
+	logger  logger.Logger
+}
+
+func NewFundingHandler(service *funding.Service, log logger.Logger) *FundingHandler {
+	return &FundingHandler{service: service, logger: log}
+}
+
+// Deposit starts a top-up of a wallet. The wallet is credited when the
+// provider confirms the payment; until then the deposit is a pending
+// transaction.
+func (h *FundingHandler) Deposit(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	walletID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+	var req funding.DepositRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	deposit, err := h.service.Initiate(r.Context(), walletID, userID, middleware.HasPermission(r.Context(), domain.PermissionTreasuryWrite), req)
+	if err != nil {
+		h.respondFundingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, deposit)
+}
+
+// Callback takes a provider's report on a deposit. Providers authenticate
+// with a signature rather than a session, so the route is public.
+func (h *FundingHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBytes))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	provider := mux.Vars(r)["provider"]
+	if err := h.service.HandleCallback(r.Context(), provider, r.Header, body); err != nil {
+		h.respondFundingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Withdraw starts cashing out of a wallet to a bank account or mobile
+// money wallet. The amount is reserved at once; large withdrawals wait for
+// an admin before they are paid out.
+func (h *FundingHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	walletID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+	var req funding.WithdrawRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	withdrawal, err := h.service.Withdraw(r.Context(), walletID, userID, req)
+	if err != nil {
+		h.respondFundingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, withdrawal)
+}
+
+// PayoutCallback takes a payout provider's report on a withdrawal.
+func (h *FundingHandler) PayoutCallback(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBytes))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	provider := mux.Vars(r)["provider"]
+	if err := h.service.HandlePayoutCallback(r.Context(), provider, r.Header, body); err != nil {
+		h.respondFundingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ListWithdrawals returns withdrawals, optionally filtered by status, e.g.
+// pending_approval for the approval queue (admin).
+func (h *FundingHandler) ListWithdrawals(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	status := domain.TransactionStatus(r.URL.Query().Get("status"))
+	items, total, err := h.service.ListWithdrawals(r.Context(), status, limit, offset)
+	if err != nil {
+		h.respondFundingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// ReviewWithdrawal approves or rejects a withdrawal held for approval
+// (admin).
+func (h *FundingHandler) ReviewWithdrawal(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid withdrawal ID")
+		return
+	}
+	var req struct {
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Action != "approve" && req.Action != "reject" {
+		respondError(w, http.StatusBadRequest, "action must be approve or reject")
+		return
+	}
+	tx, err := h.service.ReviewWithdrawal(r.Context(), id, adminID, req.Action == "approve", req.Reason)
+	if err != nil {
+		h.respondFundingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, tx)
+}
+
+func (h *FundingHandler) respondFundingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, funding.ErrWalletNotFound), errors.Is(err, funding.ErrUnknownProvider), errors.Is(err, funding.ErrDepositNotFound),
+		errors.Is(err, funding.ErrWithdrawalNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, funding.ErrNotWalletOwner):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, funding.ErrInvalidCallback):
+		h.logger.Warn("Rejected provider callback", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusUnauthorized, "Invalid callback")
+	case errors.Is(err, funding.ErrInvalidDeposit), errors.Is(err, funding.ErrInvalidWithdrawal), errors.Is(err, funding.ErrNoProvider),
+		errors.Is(err, funding.ErrWithdrawalRefused):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, funding.ErrInsufficientFunds), errors.Is(err, funding.ErrWithdrawalNotReviewed):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, funding.ErrProviderUnavailable):
+		respondError(w, http.StatusBadGateway, err.Error())
+	case errors.Is(err, pkgerrors.ErrMovementFrozen):
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		h.logger.Error("Funding request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/spreads"
+	"kyd/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// FXSpreadHandler serves the FX spreads set per currency pair and the
+// revenue they earn.
+type FXSpreadHandler struct {
+	service *spreads.Service
+	logger  logger.Logger
+}
+
+func NewFXSpreadHandler(service *spreads.Service, log logger.Logger) *FXSpreadHandler {
+	return &FXSpreadHandler{service: service, logger: log}
+}
+
+// List returns the spreads set (admin).
+func (h *FXSpreadHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	items, err := h.service.List(r.Context())
+	if err != nil {
+		h.respondSpreadError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"fx_spreads": items})
+}
+
+// Set creates or replaces the spread on a pair (admin).
+func (h *FXSpreadHandler) Set(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req spreads.SpreadRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	vars := mux.Vars(r)
+	sp, err := h.service.Set(r.Context(), domain.Currency(vars["from"]), domain.Currency(vars["to"]), req, adminID)
+	if err != nil {
+		h.respondSpreadError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, sp)
+}
+
+// Delete drops the spread on a pair, which goes back to the dynamic
+// spread (admin).
+func (h *FXSpreadHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	vars := mux.Vars(r)
+	if err := h.service.Delete(r.Context(), domain.Currency(vars["from"]), domain.Currency(vars["to"])); err != nil {
+		h.respondSpreadError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Revenue reports what spreads earned per day and corridor, or with
+// format=csv the day rows as a CSV export (admin).
+func (h *FXSpreadHandler) Revenue(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	q := r.URL.Query()
+	f := domain.FXRevenueFilter{BaseCurrency: domain.Currency(q.Get("base")), TargetCurrency: domain.Currency(q.Get("target"))}
+	for _, p := range []struct {
+		name string
+		into *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "invalid "+p.name+" timestamp")
+				return
+			}
+			*p.into = t
+		}
+	}
+
+	if q.Get("format") == "csv" {
+		content, err := h.service.RevenueCSV(r.Context(), f)
+		if err != nil {
+			h.respondSpreadError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "fx-revenue.csv"))
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(content)
+		return
+	}
+
+	report, err := h.service.Revenue(r.Context(), f)
+	if err != nil {
+		h.respondSpreadError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+func (h *FXSpreadHandler) respondSpreadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, spreads.ErrSpreadNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, spreads.ErrInvalidSpread), errors.Is(err, spreads.ErrInvalidReport):
+		respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("FX spread request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process fx spread request")
+	}
+}
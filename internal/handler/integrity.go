@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/integrity"
+	"kyd/pkg/logger"
+)
+
+// IntegrityHandler exposes the findings of the scheduled database integrity
+// checks to admins.
+type IntegrityHandler struct {
+	service *integrity.Service
+	logger  logger.Logger
+}
+
+func NewIntegrityHandler(service *integrity.Service, log logger.Logger) *IntegrityHandler {
+	return &IntegrityHandler{service: service, logger: log}
+}
+
+// ListFindings returns integrity findings, optionally filtered by check and
+// status (open by default, or resolved or all) (admin).
+func (h *IntegrityHandler) ListFindings(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	q := r.URL.Query()
+	check := domain.IntegrityCheck(q.Get("check"))
+	status := domain.IntegrityFindingStatus(q.Get("status"))
+	items, total, err := h.service.ListFindings(r.Context(), check, status, limit, offset)
+	if err != nil {
+		h.respondIntegrityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// ListRuns returns the latest integrity runs (admin).
+func (h *IntegrityHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, _ := parsePagination(r)
+	items, err := h.service.ListRuns(r.Context(), limit)
+	if err != nil {
+		h.respondIntegrityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// Run runs the integrity checks now and returns the run (admin).
+func (h *IntegrityHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	run, err := h.service.Run(r.Context())
+	if err != nil {
+		h.respondIntegrityError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
+
+func (h *IntegrityHandler) respondIntegrityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, integrity.ErrRunInProgress):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Integrity request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
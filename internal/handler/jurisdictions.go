@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/jurisdiction"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// JurisdictionHandler lets admins maintain the restricted jurisdictions
+// list and review its change log.
+type JurisdictionHandler struct {
+	service *jurisdiction.Service
+	logger  logger.Logger
+}
+
+func NewJurisdictionHandler(service *jurisdiction.Service, log logger.Logger) *JurisdictionHandler {
+	return &JurisdictionHandler{service: service, logger: log}
+}
+
+// List returns restrictions, optionally filtered by country and status
+// (admin).
+func (h *JurisdictionHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	q := r.URL.Query()
+	items, err := h.service.List(r.Context(), q.Get("country"), q.Get("status"))
+	if err != nil {
+		h.respondJurisdictionError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": len(items)})
+}
+
+// Get returns one restriction (admin).
+func (h *JurisdictionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid restriction ID")
+		return
+	}
+	j, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondJurisdictionError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, j)
+}
+
+// Create restricts a country from its effective date (admin).
+func (h *JurisdictionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req jurisdiction.Request
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	j, err := h.service.Create(r.Context(), req, adminID)
+	if err != nil {
+		h.respondJurisdictionError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/admin/jurisdictions/"+j.ID.String())
+	respondJSON(w, http.StatusCreated, j)
+}
+
+// Update changes a restriction, or ends one in force (admin).
+func (h *JurisdictionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid restriction ID")
+		return
+	}
+	var req jurisdiction.Request
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	j, err := h.service.Update(r.Context(), id, req, adminID)
+	if err != nil {
+		h.respondJurisdictionError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, j)
+}
+
+// Delete removes a restriction that has not taken effect (admin).
+func (h *JurisdictionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid restriction ID")
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	if err := h.service.Delete(r.Context(), id, req.Note, adminID); err != nil {
+		h.respondJurisdictionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Changes returns the change log, newest first, or with format=csv all of
+// it as a CSV export for regulators (admin).
+func (h *JurisdictionHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	q := r.URL.Query()
+	f := domain.JurisdictionChangeFilter{CountryCode: q.Get("country")}
+	for _, p := range []struct {
+		name string
+		into *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "invalid "+p.name+" timestamp")
+				return
+			}
+			*p.into = t
+		}
+	}
+
+	if q.Get("format") == "csv" {
+		content, err := h.service.ChangeLogCSV(r.Context(), f)
+		if err != nil {
+			h.respondJurisdictionError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "restricted-jurisdictions-changes.csv"))
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(content)
+		return
+	}
+
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.Changes(r.Context(), f, limit, offset)
+	if err != nil {
+		h.respondJurisdictionError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+func (h *JurisdictionHandler) respondJurisdictionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jurisdiction.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, jurisdiction.ErrInvalid):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, jurisdiction.ErrOverlap), errors.Is(err, jurisdiction.ErrInEffect),
+		errors.Is(err, jurisdiction.ErrConflict):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Restricted jurisdiction request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
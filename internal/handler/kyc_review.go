@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+
+	"kyd/internal/compliance"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ListReviewQueue returns the KYC profiles waiting for a reviewer, or with
+// ?queue=verification those still waiting for their virus scan (admin).
+func (h *ComplianceHandler) ListReviewQueue(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	limit, offset := parsePagination(r)
+	queue := r.URL.Query().Get("queue")
+	items, total, err := h.service.ListQueue(r.Context(), queue, limit, offset)
+	if err != nil {
+		h.respondReviewError(w, err)
+		return
+	}
+	if queue == "" {
+		queue = compliance.QueueReview
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"queue":  queue,
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetReviewProfile returns a user's KYC profile with short-lived links to
+// their document files (admin).
+func (h *ComplianceHandler) GetReviewProfile(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	profile, err := h.service.GetProfile(r.Context(), userID, adminID)
+	if err != nil {
+		h.respondReviewError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, profile)
+}
+
+// DecideProfile approves or rejects a KYC profile in the review queue, or
+// asks the user for more information (admin).
+func (h *ComplianceHandler) DecideProfile(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req struct {
+		Decision domain.KYCDecision `json:"decision"`
+		Notes    string             `json:"notes"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	review, err := h.service.DecideProfile(r.Context(), userID, adminID, req.Decision, req.Notes)
+	if err != nil {
+		h.respondReviewError(w, err)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, review)
+}
+
+// DownloadDocument serves a KYC document file. It is authorised by the
+// link signature rather than a session.
+func (h *ComplianceHandler) DownloadDocument(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	docID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid document id")
+		return
+	}
+
+	q := r.URL.Query()
+	f, fileURL, err := h.service.OpenDocumentFile(r.Context(), docID, vars["side"], q.Get("expires"), q.Get("signature"))
+	if err != nil {
+		h.respondReviewError(w, err)
+		return
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(fileURL))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, f); err != nil {
+		h.logger.Error("Failed to send kyc document", map[string]interface{}{"error": err.Error(), "document_id": docID})
+	}
+}
+
+func (h *ComplianceHandler) respondReviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pkgerrors.ErrUserNotFound), errors.Is(err, compliance.ErrDocumentNotFound):
+		h.respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, compliance.ErrInvalidLink):
+		h.respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, compliance.ErrInvalidDecision), errors.Is(err, compliance.ErrNotesRequired), errors.Is(err, compliance.ErrInvalidQueue):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, compliance.ErrNothingToReview), errors.Is(err, compliance.ErrDocumentNotScanned),
+		errors.Is(err, compliance.ErrSanctionsMatch), errors.Is(err, compliance.ErrNotScreened):
+		h.respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("KYC review request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process kyc review")
+	}
+}
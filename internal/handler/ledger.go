@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	"kyd/pkg/logger"
+)
+
+// LedgerHandler exposes the general ledger: the chart of accounts and the
+// trial balance.
+type LedgerHandler struct {
+	service *ledger.Service
+	logger  logger.Logger
+}
+
+func NewLedgerHandler(service *ledger.Service, log logger.Logger) *LedgerHandler {
+	return &LedgerHandler{service: service, logger: log}
+}
+
+// ListAccounts returns the chart of accounts (admin).
+func (h *LedgerHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"accounts": domain.ChartOfAccounts})
+}
+
+// TrialBalance returns every account's totals per currency, as of now or
+// the RFC 3339 time in as_of (admin).
+func (h *LedgerHandler) TrialBalance(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	asOf := time.Now().UTC()
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "as_of must be an RFC 3339 time")
+			return
+		}
+		asOf = t
+	}
+	tb, err := h.service.TrialBalance(r.Context(), asOf)
+	if err != nil {
+		h.logger.Error("Trial balance failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to compute trial balance")
+		return
+	}
+	respondJSON(w, http.StatusOK, tb)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// TransactionLimitsProvider reports a user's sending limits.
+type TransactionLimitsProvider interface {
+	GetTransactionLimits(ctx context.Context, userID uuid.UUID) (*payment.TransactionLimits, error)
+}
+
+type rateLimit struct {
+	limiter *middleware.RateLimiter
+	perUser bool
+}
+
+// LimitsHandler reports the caller's rate limit and transaction quotas.
+type LimitsHandler struct {
+	limits     TransactionLimitsProvider
+	rateLimits []rateLimit
+	logger     logger.Logger
+}
+
+func NewLimitsHandler(limits TransactionLimitsProvider, log logger.Logger) *LimitsHandler {
+	return &LimitsHandler{limits: limits, logger: log}
+}
+
+// WithRateLimiter adds a limiter to the report. perUser is true when the
+// limiter runs after authentication and so counts per user rather than per IP.
+func (h *LimitsHandler) WithRateLimiter(rl *middleware.RateLimiter, perUser bool) *LimitsHandler {
+	h.rateLimits = append(h.rateLimits, rateLimit{limiter: rl, perUser: perUser})
+	return h
+}
+
+type limitsResponse struct {
+	RateLimits        []middleware.Quota         `json:"rate_limits"`
+	TransactionLimits *payment.TransactionLimits `json:"transaction_limits"`
+}
+
+// Get returns the caller's remaining rate limit quotas and transaction
+// limits. Reading them does not count against any limit beyond the request
+// itself.
+func (h *LimitsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	resp := limitsResponse{RateLimits: []middleware.Quota{}}
+	ip := middleware.ClientIP(r)
+	for _, rl := range h.rateLimits {
+		id := uuid.Nil
+		if rl.perUser {
+			id = userID
+		}
+		q, err := rl.limiter.Quota(r.Context(), ip, id)
+		if err != nil {
+			// Limiters fail open, so an unreadable counter is not a limit.
+			h.logger.Warn("Failed to read rate limit quota", map[string]interface{}{"error": err.Error(), "limiter": rl.limiter.Name()})
+			continue
+		}
+		resp.RateLimits = append(resp.RateLimits, q)
+	}
+
+	limits, err := h.limits.GetTransactionLimits(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to fetch transaction limits", map[string]interface{}{"error": err.Error(), "user_id": userID})
+		respondError(w, http.StatusInternalServerError, "Failed to fetch limits")
+		return
+	}
+	resp.TransactionLimits = limits
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"kyd/internal/maintenance"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+type MaintenanceHandler struct {
+	mode   *maintenance.Mode
+	logger logger.Logger
+}
+
+func NewMaintenanceHandler(mode *maintenance.Mode, log logger.Logger) *MaintenanceHandler {
+	return &MaintenanceHandler{mode: mode, logger: log}
+}
+
+// List returns the active maintenance windows.
+func (h *MaintenanceHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	windows, err := h.mode.Windows(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list maintenance windows", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to list maintenance windows")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"windows": windows})
+}
+
+// Enable puts a service, or "all", into maintenance.
+func (h *MaintenanceHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	actorID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req struct {
+		Message           string `json:"message"`
+		RetryAfterSeconds int    `json:"retry_after_seconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeStrict(w, r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	window, err := h.mode.Enable(r.Context(), mux.Vars(r)["scope"], req.Message, time.Duration(req.RetryAfterSeconds)*time.Second, &actorID)
+	if errors.Is(err, maintenance.ErrUnknownScope) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to enable maintenance", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to enable maintenance")
+		return
+	}
+	h.logger.Info("Maintenance enabled", map[string]interface{}{"scope": window.Scope, "actor_id": actorID})
+	respondJSON(w, http.StatusOK, window)
+}
+
+// Disable ends a runtime maintenance window. Windows forced by configuration remain.
+func (h *MaintenanceHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	actorID, _ := middleware.UserIDFromContext(r.Context())
+	scope := mux.Vars(r)["scope"]
+
+	err := h.mode.Disable(r.Context(), scope)
+	if errors.Is(err, maintenance.ErrUnknownScope) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to disable maintenance", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to disable maintenance")
+		return
+	}
+	h.logger.Info("Maintenance disabled", map[string]interface{}{"scope": scope, "actor_id": actorID})
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Maintenance disabled"})
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	"kyd/internal/wallet"
+	"kyd/pkg/mailer"
+	"kyd/pkg/masking"
+
+	"github.com/google/uuid"
+)
+
+// Personal data in admin list endpoints is masked unless the caller's roles
+// grant the users:personal_data permission. Masking works on copies, so
+// nothing returned by the services is modified.
+
+func seesUnmaskedData(r *http.Request) bool {
+	return middleware.HasPermission(r.Context(), domain.PermissionPersonalData)
+}
+
+func maskUser(u *domain.User) *domain.User {
+	if u == nil {
+		return nil
+	}
+	m := *u
+	m.Email = masking.Email(u.Email)
+	m.Phone = masking.Phone(u.Phone)
+	if r := []rune(u.LastName); len(r) > 0 {
+		m.LastName = string(r[0]) + "."
+	}
+	m.DateOfBirth = nil
+	m.TaxID = masking.Account(u.TaxID)
+	m.PostalCode = ""
+	if u.BusinessRegistration != nil {
+		reg := masking.Account(*u.BusinessRegistration)
+		m.BusinessRegistration = &reg
+	}
+	return &m
+}
+
+func maskUsers(users []*domain.User) []*domain.User {
+	out := make([]*domain.User, len(users))
+	for i, u := range users {
+		out[i] = maskUser(u)
+	}
+	return out
+}
+
+func maskBalance(b *wallet.BalanceResponse) *wallet.BalanceResponse {
+	if b == nil {
+		return nil
+	}
+	m := *b
+	if b.WalletAddress != nil {
+		addr := masking.Account(*b.WalletAddress)
+		m.WalletAddress = &addr
+	}
+	m.FormattedWalletAddress = masking.Account(b.FormattedWalletAddress)
+	m.CardholderName = masking.Name(b.CardholderName)
+	return &m
+}
+
+func maskBalances(balances []*wallet.BalanceResponse) []*wallet.BalanceResponse {
+	out := make([]*wallet.BalanceResponse, len(balances))
+	for i, b := range balances {
+		out[i] = maskBalance(b)
+	}
+	return out
+}
+
+func maskTransactionDetail(d *payment.TransactionDetail) *payment.TransactionDetail {
+	if d == nil {
+		return nil
+	}
+	m := *d
+	m.SenderName = masking.Name(d.SenderName)
+	m.ReceiverName = masking.Name(d.ReceiverName)
+	m.SenderWalletNumber = masking.Account(d.SenderWalletNumber)
+	m.ReceiverWalletNumber = masking.Account(d.ReceiverWalletNumber)
+	return &m
+}
+
+func maskTransactionDetails(details []*payment.TransactionDetail) []*payment.TransactionDetail {
+	out := make([]*payment.TransactionDetail, len(details))
+	for i, d := range details {
+		out[i] = maskTransactionDetail(d)
+	}
+	return out
+}
+
+type unmaskRequest struct {
+	EntityType string `json:"entity_type" validate:"required,oneof=user wallet transaction"`
+	EntityID   string `json:"entity_id" validate:"required,uuid"`
+	Reason     string `json:"reason" validate:"required,min=10,max=500"`
+}
+
+// Unmask returns one user, wallet or transaction with its personal data in
+// full to staff holding users:unmask. The reason is mandatory and the
+// request is audited before anything is returned; if the audit entry cannot
+// be written the data is withheld.
+func (h *UsersHandler) Unmask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !middleware.HasPermission(ctx, domain.PermissionUsersUnmask) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	actorID, _ := middleware.UserIDFromContext(ctx)
+
+	var req unmaskRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	id := uuid.MustParse(req.EntityID)
+
+	var record interface{}
+	var err error
+	switch req.EntityType {
+	case "user":
+		record, err = h.service.GetUserByID(ctx, id)
+	case "wallet":
+		if h.walletSvc == nil {
+			respondError(w, http.StatusNotImplemented, "Wallets are not served here")
+			return
+		}
+		record, err = h.walletSvc.GetBalance(ctx, id)
+	case "transaction":
+		if h.paymentSvc == nil {
+			respondError(w, http.StatusNotImplemented, "Transactions are not served here")
+			return
+		}
+		record, err = h.paymentSvc.GetTransaction(ctx, id)
+	}
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+
+	newValues, _ := json.Marshal(map[string]interface{}{
+		"reason":     req.Reason,
+		"admin_role": middleware.AdminRoleFromContext(ctx),
+	})
+	entry := &domain.AuditLog{
+		ID:         uuid.New(),
+		UserID:     &actorID,
+		Action:     "UNMASK",
+		EntityType: req.EntityType,
+		EntityID:   id.String(),
+		IPAddress:  middleware.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		Status:     "success",
+		StatusCode: http.StatusOK,
+		NewValues:  newValues,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.auditRepo.Create(ctx, entry); err != nil {
+		h.logger.Error("Failed to audit unmask", map[string]interface{}{"error": err.Error(), "entity_type": req.EntityType})
+		respondError(w, http.StatusInternalServerError, "Unable to record unmask request")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"entity_type": req.EntityType,
+		"entity_id":   id,
+		"record":      record,
+	})
+}
+
+func maskEmailMessages(msgs []*mailer.Message) []*mailer.Message {
+	out := make([]*mailer.Message, len(msgs))
+	for i, msg := range msgs {
+		m := *msg
+		m.To = masking.Email(msg.To)
+		out[i] = &m
+	}
+	return out
+}
+
+func maskUserImportRows(rows []domain.UserImportRow) []domain.UserImportRow {
+	out := make([]domain.UserImportRow, len(rows))
+	for i, row := range rows {
+		rec := &row.Record
+		rec.Email = masking.Email(rec.Email)
+		rec.Phone = masking.Phone(rec.Phone)
+		if r := []rune(rec.LastName); len(r) > 0 {
+			rec.LastName = string(r[0]) + "."
+		}
+		rec.DateOfBirth = ""
+		rec.TaxID = masking.Account(rec.TaxID)
+		rec.PostalCode = ""
+		out[i] = row
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/auth"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockUsers serves the user lookups Unmask makes; other repository calls
+// are not expected.
+type mockUsers struct {
+	auth.Repository
+	mock.Mock
+}
+
+func (m *mockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	u, _ := args.Get(0).(*domain.User)
+	return u, args.Error(1)
+}
+
+type mockAudit struct {
+	AuditRepository
+	mock.Mock
+}
+
+func (m *mockAudit) Create(ctx context.Context, entry *domain.AuditLog) error {
+	return m.Called(ctx, entry).Error(0)
+}
+
+func TestUnmask_RequiresUnmaskPermission(t *testing.T) {
+	user := &domain.User{ID: uuid.New(), Email: "chikondi@example.com"}
+	users, audit := &mockUsers{}, &mockAudit{}
+	users.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+	audit.On("Create", mock.Anything, mock.MatchedBy(func(e *domain.AuditLog) bool {
+		return e.Action == "UNMASK" && e.EntityID == user.ID.String()
+	})).Return(nil).Once()
+	h := NewUsersHandler(auth.NewService(users, nil, "secret", time.Hour), validator.New(), logger.NewNop(), audit, nil, nil, nil)
+
+	unmask := func(perms ...domain.Permission) *httptest.ResponseRecorder {
+		body := `{"entity_type":"user","entity_id":"` + user.ID.String() + `","reason":"Customer verified on call, ticket 4821"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/unmask", strings.NewReader(body))
+		req = req.WithContext(middleware.WithPermissions(req.Context(), domain.NewPermissionSet(perms...)))
+		rec := httptest.NewRecorder()
+		h.Unmask(rec, req)
+		return rec
+	}
+
+	support := unmask(domain.PermissionUsersRead, domain.PermissionComplianceRead, domain.PermissionTransactionsRead)
+	assert.Equal(t, http.StatusForbidden, support.Code)
+	users.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+	audit.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	compliance := unmask(domain.PermissionUsersRead, domain.PermissionUsersUnmask)
+	assert.Equal(t, http.StatusOK, compliance.Code)
+	assert.Contains(t, compliance.Body.String(), "chikondi@example.com")
+	audit.AssertExpectations(t)
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/invoice"
+	"kyd/internal/middleware"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// InvoiceHandler lets merchants request money with invoices, and payers pay
+// them through their payment links.
+type InvoiceHandler struct {
+	service *invoice.Service
+	logger  logger.Logger
+}
+
+func NewInvoiceHandler(service *invoice.Service, log logger.Logger) *InvoiceHandler {
+	return &InvoiceHandler{service: service, logger: log}
+}
+
+// invoiceResponse adds the payment link to a merchant's invoice.
+type invoiceResponse struct {
+	*domain.MerchantInvoice
+	PaymentLink string `json:"payment_link"`
+}
+
+func (h *InvoiceHandler) newInvoiceResponse(inv *domain.MerchantInvoice) invoiceResponse {
+	return invoiceResponse{MerchantInvoice: inv, PaymentLink: h.service.PaymentLink(inv)}
+}
+
+// Create issues an invoice from the calling merchant, as a draft or, with
+// "send": true, ready to be paid.
+func (h *InvoiceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req invoice.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.MerchantID = userID
+	inv, err := h.service.Create(r.Context(), &req)
+	if err != nil {
+		h.respondInvoiceError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, h.newInvoiceResponse(inv))
+}
+
+// List returns the caller's invoices, newest first, optionally filtered by
+// status.
+func (h *InvoiceHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	status := domain.MerchantInvoiceStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", domain.MerchantInvoiceDraft, domain.MerchantInvoiceSent, domain.MerchantInvoicePaid, domain.MerchantInvoiceExpired, domain.MerchantInvoiceCancelled:
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	limit, offset := parsePagination(r)
+	invoices, total, err := h.service.List(r.Context(), userID, status, limit, offset)
+	if err != nil {
+		h.respondInvoiceError(w, err)
+		return
+	}
+	items := make([]invoiceResponse, len(invoices))
+	for i := range invoices {
+		items[i] = h.newInvoiceResponse(&invoices[i])
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Get returns one of the caller's invoices.
+func (h *InvoiceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	h.merchantAction(w, r, h.service.Get)
+}
+
+// Send makes a draft invoice payable.
+func (h *InvoiceHandler) Send(w http.ResponseWriter, r *http.Request) {
+	h.merchantAction(w, r, h.service.Send)
+}
+
+// Cancel withdraws a draft or sent invoice.
+func (h *InvoiceHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.merchantAction(w, r, h.service.Cancel)
+}
+
+func (h *InvoiceHandler) merchantAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, merchantID, id uuid.UUID) (*domain.MerchantInvoice, error)) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	inv, err := action(r.Context(), userID, id)
+	if err != nil {
+		h.respondInvoiceError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, h.newInvoiceResponse(inv))
+}
+
+// payableResponse is what a payer is shown of an invoice.
+type payableResponse struct {
+	ID            uuid.UUID                    `json:"id"`
+	Merchant      string                       `json:"merchant"`
+	Reference     string                       `json:"reference,omitempty"`
+	Amount        decimal.Decimal              `json:"amount"`
+	Currency      domain.Currency              `json:"currency"`
+	Memo          string                       `json:"memo,omitempty"`
+	Status        domain.MerchantInvoiceStatus `json:"status"`
+	ExpiresAt     time.Time                    `json:"expires_at"`
+	TransactionID *uuid.UUID                   `json:"transaction_id,omitempty"`
+}
+
+// View shows the invoice behind a payment link to the caller before they
+// pay it.
+func (h *InvoiceHandler) View(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	p, err := h.service.View(r.Context(), userID, mux.Vars(r)["token"])
+	if err != nil {
+		h.respondInvoiceError(w, err)
+		return
+	}
+	resp := payableResponse{
+		ID:        p.Invoice.ID,
+		Merchant:  p.MerchantName,
+		Reference: p.Invoice.Reference,
+		Amount:    p.Invoice.Amount,
+		Currency:  p.Invoice.Currency,
+		Memo:      p.Invoice.Memo,
+		Status:    p.Invoice.Status,
+		ExpiresAt: p.Invoice.ExpiresAt,
+	}
+	if p.Invoice.PaidBy != nil && *p.Invoice.PaidBy == userID {
+		resp.TransactionID = p.Invoice.TransactionID
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// Pay pays the invoice behind a payment link from the caller's wallet in
+// its currency. Payments that need step-up carry it as for any payment.
+func (h *InvoiceHandler) Pay(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		StepUp *invoice.StepUp `json:"step_up"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	inv, err := h.service.Pay(r.Context(), userID, mux.Vars(r)["token"], req.StepUp)
+	if err != nil {
+		switch {
+		case errors.Is(err, invoice.ErrNotFound), errors.Is(err, invoice.ErrNotPayable):
+			h.respondInvoiceError(w, err)
+		case errors.Is(err, pkgerrors.ErrStepUpLocked):
+			respondError(w, http.StatusTooManyRequests, err.Error())
+		case errors.Is(err, pkgerrors.ErrMovementFrozen):
+			respondError(w, http.StatusServiceUnavailable, err.Error())
+		case errors.Is(err, pkgerrors.ErrJurisdictionRestricted):
+			respondError(w, http.StatusForbidden, err.Error())
+		default:
+			// Refused like any other payment, e.g. for want of funds
+			h.logger.Warn("Invoice payment refused", map[string]interface{}{"error": err.Error(), "payer_id": userID})
+			respondError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"invoice_id":     inv.ID,
+		"status":         inv.Status,
+		"transaction_id": inv.TransactionID,
+		"paid_at":        inv.PaidAt,
+	})
+}
+
+func (h *InvoiceHandler) respondInvoiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, invoice.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Invoice not found")
+	case errors.Is(err, invoice.ErrInvalidInvoice):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, invoice.ErrNotMerchant):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, invoice.ErrInvalidStatus), errors.Is(err, invoice.ErrNotPayable), errors.Is(err, invoice.ErrReferenceExists):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Invoice request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
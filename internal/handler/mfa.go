@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/auth"
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	pkgerrors "kyd/pkg/errors"
+)
+
+type mfaSendCodeRequest struct {
+	MFAToken string           `json:"mfa_token" validate:"required"`
+	Method   domain.MFAMethod `json:"method" validate:"required"`
+}
+
+// SendMFACode sends an SMS or email code for a login challenge.
+func (h *AuthHandler) SendMFACode(w http.ResponseWriter, r *http.Request) {
+	var req mfaSendCodeRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		h.respondValidationErrors(w, errs)
+		return
+	}
+	if err := h.service.SendMFACode(r.Context(), req.MFAToken, req.Method); err != nil {
+		h.respondMFAError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusAccepted, map[string]string{"status": "code sent"})
+}
+
+// VerifyMFA completes a login challenge with a second factor and issues
+// tokens as Login does.
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req auth.MFAVerifyRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		h.respondValidationErrors(w, errs)
+		return
+	}
+
+	// Enrich with device info as Login does
+	req.IPAddress = r.Header.Get("X-Forwarded-For")
+	if req.IPAddress == "" {
+		req.IPAddress = r.RemoteAddr
+	}
+	req.DeviceName = r.Header.Get("User-Agent")
+	if req.DeviceID == "" {
+		req.DeviceID = r.Header.Get("X-Device-ID")
+		if req.DeviceID == "" && req.DeviceName != "" {
+			req.DeviceID = req.DeviceName
+		}
+	}
+
+	response, err := h.service.VerifyMFA(r.Context(), &req)
+	if err != nil {
+		h.logger.Warn("Second factor failed", map[string]interface{}{
+			"event":  "login_mfa_failed",
+			"method": req.Method,
+			"error":  err.Error(),
+			"ip":     req.IPAddress,
+		})
+		h.respondMFAError(w, err)
+		return
+	}
+	h.loginSucceeded(w, r, &auth.LoginRequest{
+		Email:       response.User.Email,
+		DeviceID:    req.DeviceID,
+		DeviceName:  req.DeviceName,
+		IPAddress:   req.IPAddress,
+		CountryCode: req.CountryCode,
+	}, response)
+}
+
+// MFAStatus reports the authenticated user's two-factor setup.
+func (h *AuthHandler) MFAStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	st, err := h.service.MFAStatus(r.Context(), userID)
+	if err != nil {
+		h.respondMFAError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, st)
+}
+
+type regenerateBackupCodesRequest struct {
+	Password string `json:"password"`
+	TOTPCode string `json:"totp_code"`
+}
+
+// RegenerateBackupCodes replaces the authenticated user's backup codes
+// after re-authenticating them. Earlier codes stop working.
+func (h *AuthHandler) RegenerateBackupCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req regenerateBackupCodesRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.service.VerifyStepUp(r.Context(), userID, req.Password, req.TOTPCode); err != nil {
+		switch err {
+		case pkgerrors.ErrTOTPRequired:
+			h.respondError(w, http.StatusUnauthorized, "TOTP code required")
+		case pkgerrors.ErrStepUpLocked:
+			h.respondError(w, http.StatusTooManyRequests, "Too many failed verification attempts, try again later")
+		default:
+			h.respondError(w, http.StatusUnauthorized, "Invalid credentials")
+		}
+		return
+	}
+	codes, err := h.service.RegenerateBackupCodes(r.Context(), userID)
+	if err != nil {
+		h.respondMFAError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"backup_codes": codes})
+}
+
+// GetMFAPolicy returns the policy forcing MFA on some accounts (admin).
+func (h *AuthHandler) GetMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	p, err := h.service.MFAPolicy(r.Context())
+	if err != nil {
+		h.respondMFAError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, p)
+}
+
+type mfaPolicyRequest struct {
+	RequiredForAdmins bool `json:"required_for_admins"`
+	RequiredKYCLevel  int  `json:"required_kyc_level"`
+}
+
+// SetMFAPolicy replaces the policy; it applies from the next login (admin).
+func (h *AuthHandler) SetMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req mfaPolicyRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	p := &domain.MFAPolicy{RequiredForAdmins: req.RequiredForAdmins, RequiredKYCLevel: req.RequiredKYCLevel}
+	if err := h.service.SetMFAPolicy(r.Context(), p, adminID); err != nil {
+		h.respondMFAError(w, err)
+		return
+	}
+	h.logger.Info("MFA policy changed", map[string]interface{}{
+		"event":               "mfa_policy_changed",
+		"admin_id":            adminID,
+		"required_for_admins": p.RequiredForAdmins,
+		"required_kyc_level":  p.RequiredKYCLevel,
+	})
+	h.respondJSON(w, http.StatusOK, p)
+}
+
+func (h *AuthHandler) respondMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFAToken):
+		h.respondError(w, http.StatusUnauthorized, "Sign-in expired, please log in again")
+	case errors.Is(err, pkgerrors.ErrInvalidTOTP):
+		h.respondError(w, http.StatusUnauthorized, "Invalid code")
+	case errors.Is(err, auth.ErrMFAMethodUnavailable), errors.Is(err, auth.ErrInvalidMFAPolicy):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, auth.ErrMFANotConfigured):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, pkgerrors.ErrVerificationThrottled):
+		h.respondError(w, http.StatusTooManyRequests, "A code was sent recently, please wait before requesting another")
+	case errors.Is(err, pkgerrors.ErrTooManyCodeAttempts):
+		h.respondError(w, http.StatusTooManyRequests, "Too many attempts, please request a new code")
+	case errors.Is(err, pkgerrors.ErrStepUpLocked):
+		h.respondError(w, http.StatusTooManyRequests, "Too many failed verification attempts, try again later")
+	default:
+		h.logger.Error("MFA request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
@@ -22,11 +22,14 @@ type NotificationHandler struct {
 	repo    NotificationRepository
 }
 
-// NotificationRepository defines read access to stored notifications.
+// NotificationRepository defines access to a user's stored notifications.
+// The methods reporting false found no such notification of the user.
 type NotificationRepository interface {
-	ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Notification, int, error)
-	MarkRead(ctx context.Context, id uuid.UUID) error
-	Archive(ctx context.Context, id uuid.UUID) error
+	ListByUser(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]*domain.Notification, int, error)
+	CountUnread(ctx context.Context, userID uuid.UUID) (int, error)
+	MarkReadForUser(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	MarkAllReadForUser(ctx context.Context, userID uuid.UUID) (int, error)
+	ArchiveForUser(ctx context.Context, userID, id uuid.UUID) (bool, error)
 }
 
 func NewNotificationHandler(service *notification.DefaultService, repo NotificationRepository, log logger.Logger) *NotificationHandler {
@@ -42,6 +45,8 @@ type apiNotification struct {
 	Type      string                 `json:"type"`
 	Subject   string                 `json:"subject"`
 	Body      string                 `json:"body"`
+	IsRead    bool                   `json:"is_read"`
+	ReadAt    *time.Time             `json:"read_at,omitempty"`
 	CreatedAt time.Time              `json:"created_at"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -70,7 +75,9 @@ func (h *NotificationHandler) List(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	records, total, err := h.repo.ListByUser(r.Context(), userID, limit, offset)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	records, total, err := h.repo.ListByUser(r.Context(), userID, unreadOnly, limit, offset)
 	if err != nil {
 		h.logger.Error("Failed to list notifications", map[string]interface{}{
 			"error":   err.Error(),
@@ -88,19 +95,70 @@ func (h *NotificationHandler) List(w http.ResponseWriter, r *http.Request) {
 			Type:      n.Type,
 			Subject:   n.Title,
 			Body:      n.Message,
+			IsRead:    n.IsRead,
+			ReadAt:    n.ReadAt,
 			CreatedAt: n.CreatedAt,
 			Metadata:  metadata,
 		})
 	}
 
+	unread, err := h.repo.CountUnread(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to count unread notifications", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": userID,
+		})
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
 	h.respondJSON(w, http.StatusOK, map[string]interface{}{
 		"notifications": notifications,
 		"total":         total,
+		"unread_count":  unread,
 		"limit":         limit,
 		"offset":        offset,
 	})
 }
 
+// UnreadCount returns how many of the caller's notifications are unread,
+// for the bell icon to poll cheaply.
+func (h *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	unread, err := h.repo.CountUnread(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to count unread notifications", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": userID,
+		})
+		h.respondError(w, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]int{"unread_count": unread})
+}
+
+// MarkAllRead marks every unread notification of the caller read.
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	marked, err := h.repo.MarkAllReadForUser(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to mark all notifications read", map[string]interface{}{"error": err.Error(), "user_id": userID})
+		h.respondError(w, http.StatusInternalServerError, "Failed to mark all notifications read")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"marked": marked, "unread_count": 0})
+}
+
 func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromContext(r.Context())
 	if !ok {
@@ -115,13 +173,16 @@ func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Best-effort: we don't currently enforce ownership check at DB level here.
-	// The ID is unguessable UUID; admin screens have stronger controls.
-	if err := h.repo.MarkRead(r.Context(), id); err != nil {
+	found, err := h.repo.MarkReadForUser(r.Context(), userID, id)
+	if err != nil {
 		h.logger.Error("Failed to mark notification read", map[string]interface{}{"error": err.Error(), "user_id": userID, "id": idStr})
 		h.respondError(w, http.StatusInternalServerError, "Failed to mark notification read")
 		return
 	}
+	if !found {
+		h.respondError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
 	h.respondJSON(w, http.StatusOK, map[string]string{"message": "ok"})
 }
 
@@ -139,11 +200,16 @@ func (h *NotificationHandler) Archive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.repo.Archive(r.Context(), id); err != nil {
+	found, err := h.repo.ArchiveForUser(r.Context(), userID, id)
+	if err != nil {
 		h.logger.Error("Failed to archive notification", map[string]interface{}{"error": err.Error(), "user_id": userID, "id": idStr})
 		h.respondError(w, http.StatusInternalServerError, "Failed to archive notification")
 		return
 	}
+	if !found {
+		h.respondError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
 	h.respondJSON(w, http.StatusOK, map[string]string{"message": "ok"})
 }
 
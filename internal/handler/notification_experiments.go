@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/notification"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Track records that the caller opened or clicked through from one of
+// their notifications, as named by the path. It succeeds for notifications
+// not under test too, so the app may report every one.
+func (h *NotificationHandler) Track(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+	if err := h.service.Track(r.Context(), userID, id, notification.TrackingEvent(vars["event"])); err != nil {
+		h.respondExperimentError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+}
+
+// CreateExperiment starts testing variants of a notification (admin).
+func (h *NotificationHandler) CreateExperiment(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req notification.ExperimentRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	staffID, _ := middleware.UserIDFromContext(r.Context())
+	e, err := h.service.CreateExperiment(r.Context(), staffID, &req)
+	if err != nil {
+		h.respondExperimentError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, e)
+}
+
+// ListExperiments lists notification experiments, newest first (admin).
+func (h *NotificationHandler) ListExperiments(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.Experiments(r.Context(), limit, offset)
+	if err != nil {
+		h.respondExperimentError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// ExperimentResults returns an experiment with its deliveries, opens and
+// clicks per variant (admin).
+func (h *NotificationHandler) ExperimentResults(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid experiment ID")
+		return
+	}
+	results, err := h.service.Results(r.Context(), id)
+	if err != nil {
+		h.respondExperimentError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// StopExperiment ends an experiment, keeping its results (admin).
+func (h *NotificationHandler) StopExperiment(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid experiment ID")
+		return
+	}
+	e, err := h.service.StopExperiment(r.Context(), id)
+	if err != nil {
+		h.respondExperimentError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, e)
+}
+
+func (h *NotificationHandler) respondExperimentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notification.ErrExperimentsNotConfigured):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, notification.ErrInvalidExperiment), errors.Is(err, notification.ErrInvalidTrackingEvent):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, notification.ErrExperimentNotFound):
+		h.respondError(w, http.StatusNotFound, "Experiment not found")
+	case errors.Is(err, notification.ErrExperimentRunning), errors.Is(err, notification.ErrExperimentStopped):
+		h.respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Notification experiment request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/notification"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ListPushTokens returns the devices registered for the caller's push
+// notifications.
+func (h *NotificationHandler) ListPushTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	tokens, err := h.service.PushTokens(r.Context(), userID)
+	if err != nil {
+		h.respondSettingsError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"items": tokens})
+}
+
+// RegisterPushToken registers a device's FCM or APNs token for the
+// caller's push notifications.
+func (h *NotificationHandler) RegisterPushToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Provider   domain.PushProvider `json:"provider"`
+		Token      string              `json:"token"`
+		DeviceName string              `json:"device_name"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	token, err := h.service.RegisterPushToken(r.Context(), userID, req.Provider, req.Token, req.DeviceName)
+	if err != nil {
+		h.respondSettingsError(w, err)
+		return
+	}
+	h.logger.Info("Push token registered", map[string]interface{}{
+		"event":    "push_token_registered",
+		"user_id":  userID,
+		"token_id": token.ID,
+		"provider": token.Provider,
+	})
+	h.respondJSON(w, http.StatusCreated, token)
+}
+
+// UnregisterPushToken stops pushing to one of the caller's devices.
+func (h *NotificationHandler) UnregisterPushToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid push token ID")
+		return
+	}
+	if err := h.service.UnregisterPushToken(r.Context(), userID, id); err != nil {
+		h.respondSettingsError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPreferences returns the channels the caller has on for each kind of
+// notification.
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	prefs, err := h.service.Preferences(r.Context(), userID)
+	if err != nil {
+		h.respondSettingsError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"items": prefs})
+}
+
+// UpdatePreferences turns channels on or off for kinds of notification.
+// Those not in the request keep their setting.
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		Preferences []struct {
+			EventType string                     `json:"event_type"`
+			Channel   domain.NotificationChannel `json:"channel"`
+			Enabled   *bool                      `json:"enabled"`
+		} `json:"preferences"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Preferences) == 0 {
+		h.respondError(w, http.StatusBadRequest, "preferences is required")
+		return
+	}
+	prefs := make([]domain.NotificationPreference, 0, len(req.Preferences))
+	for _, p := range req.Preferences {
+		if p.Enabled == nil {
+			h.respondError(w, http.StatusBadRequest, "enabled is required for each preference")
+			return
+		}
+		prefs = append(prefs, domain.NotificationPreference{EventType: p.EventType, Channel: p.Channel, Enabled: *p.Enabled})
+	}
+	out, err := h.service.SetPreferences(r.Context(), userID, prefs)
+	if err != nil {
+		h.respondSettingsError(w, err)
+		return
+	}
+	h.logger.Info("Notification preferences updated", map[string]interface{}{
+		"event":   "notification_preferences_updated",
+		"user_id": userID,
+		"count":   len(prefs),
+	})
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"items": out})
+}
+
+func (h *NotificationHandler) respondSettingsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notification.ErrPushNotConfigured), errors.Is(err, notification.ErrPreferencesNotConfigured):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, notification.ErrUnsupportedProvider), errors.Is(err, notification.ErrInvalidPushToken),
+		errors.Is(err, notification.ErrInvalidPreference):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, notification.ErrPushTokenNotFound):
+		h.respondError(w, http.StatusNotFound, "Push token not found")
+	default:
+		h.logger.Error("Notification settings request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
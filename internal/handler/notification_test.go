@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryNotifications keeps notifications in memory, newest last.
+type memoryNotifications struct {
+	items []*domain.Notification
+}
+
+func (m *memoryNotifications) add(userID uuid.UUID) *domain.Notification {
+	n := &domain.Notification{ID: uuid.New(), UserID: userID, Type: "payment_received", Title: "Payment Received", CreatedAt: time.Now()}
+	m.items = append(m.items, n)
+	return n
+}
+
+func (m *memoryNotifications) find(userID, id uuid.UUID) *domain.Notification {
+	for _, n := range m.items {
+		if n.ID == id && n.UserID == userID && !n.IsArchived {
+			return n
+		}
+	}
+	return nil
+}
+
+func (m *memoryNotifications) ListByUser(_ context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]*domain.Notification, int, error) {
+	var out []*domain.Notification
+	for i := len(m.items) - 1; i >= 0; i-- {
+		n := m.items[i]
+		if n.UserID == userID && !n.IsArchived && (!unreadOnly || !n.IsRead) {
+			out = append(out, n)
+		}
+	}
+	total := len(out)
+	if offset > len(out) {
+		offset = len(out)
+	}
+	out = out[offset:]
+	if limit < len(out) {
+		out = out[:limit]
+	}
+	return out, total, nil
+}
+
+func (m *memoryNotifications) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	_, n, err := m.ListByUser(ctx, userID, true, 0, 0)
+	return n, err
+}
+
+func (m *memoryNotifications) MarkReadForUser(_ context.Context, userID, id uuid.UUID) (bool, error) {
+	n := m.find(userID, id)
+	if n == nil {
+		return false, nil
+	}
+	n.IsRead = true
+	return true, nil
+}
+
+func (m *memoryNotifications) MarkAllReadForUser(_ context.Context, userID uuid.UUID) (int, error) {
+	marked := 0
+	for _, n := range m.items {
+		if n.UserID == userID && !n.IsArchived && !n.IsRead {
+			n.IsRead = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (m *memoryNotifications) ArchiveForUser(_ context.Context, userID, id uuid.UUID) (bool, error) {
+	n := m.find(userID, id)
+	if n == nil {
+		return false, nil
+	}
+	n.IsArchived = true
+	return true, nil
+}
+
+// serveAs runs h behind the real auth middleware for userID, with the
+// route's vars set.
+func serveAs(t *testing.T, h http.HandlerFunc, userID uuid.UUID, req *http.Request, vars map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": userID.String()}).SignedString([]byte(testJWTSecret))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req = mux.SetURLVars(req, vars)
+	rec := httptest.NewRecorder()
+	middleware.NewAuthMiddleware(testJWTSecret, nil).Authenticate(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNotificationCenter_UnreadState(t *testing.T) {
+	repo := &memoryNotifications{}
+	h := NewNotificationHandler(nil, repo, logger.NewNop())
+	me, someoneElse := uuid.New(), uuid.New()
+	first := repo.add(me)
+	repo.add(me)
+	theirs := repo.add(someoneElse)
+
+	unreadCount := func() int {
+		rec := serveAs(t, h.UnreadCount, me, httptest.NewRequest(http.MethodGet, "/notifications/unread-count", nil), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			UnreadCount int `json:"unread_count"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		return body.UnreadCount
+	}
+	assert.Equal(t, 2, unreadCount())
+
+	// Another user's notification is not the caller's to touch
+	rec := serveAs(t, h.MarkRead, me, httptest.NewRequest(http.MethodPost, "/notifications/x/read", nil), map[string]string{"id": theirs.ID.String()})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.False(t, theirs.IsRead)
+	rec = serveAs(t, h.Archive, me, httptest.NewRequest(http.MethodDelete, "/notifications/x", nil), map[string]string{"id": theirs.ID.String()})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serveAs(t, h.MarkRead, me, httptest.NewRequest(http.MethodPost, "/notifications/x/read", nil), map[string]string{"id": first.ID.String()})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, unreadCount())
+
+	rec = serveAs(t, h.List, me, httptest.NewRequest(http.MethodGet, "/notifications?unread=true", nil), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Notifications []apiNotification `json:"notifications"`
+		Total         int               `json:"total"`
+		UnreadCount   int               `json:"unread_count"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	assert.Equal(t, 1, list.Total)
+	assert.Equal(t, 1, list.UnreadCount)
+	require.Len(t, list.Notifications, 1)
+	assert.NotEqual(t, first.ID.String(), list.Notifications[0].ID)
+
+	rec = serveAs(t, h.MarkAllRead, me, httptest.NewRequest(http.MethodPost, "/notifications/read-all", nil), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 0, unreadCount())
+	assert.False(t, theirs.IsRead)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+)
+
+// OutboxLister lists outbox events. Satisfied by postgres.OutboxRepository.
+type OutboxLister interface {
+	ListOutbox(ctx context.Context, pending bool, eventType domain.OutboxEventType, limit, offset int) ([]domain.OutboxEvent, int, error)
+}
+
+// OutboxHandler shows admins the domain events waiting to be published to
+// the message broker and those already published.
+type OutboxHandler struct {
+	events OutboxLister
+	logger logger.Logger
+}
+
+func NewOutboxHandler(events OutboxLister, log logger.Logger) *OutboxHandler {
+	return &OutboxHandler{events: events, logger: log}
+}
+
+// List returns outbox events newest first: ?status=pending (default) or
+// published, optionally of one ?type= (admin).
+func (h *OutboxHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var pending bool
+	switch r.URL.Query().Get("status") {
+	case "", "pending":
+		pending = true
+	case "published":
+	default:
+		respondError(w, http.StatusBadRequest, "status must be pending or published")
+		return
+	}
+	limit, offset := parsePagination(r)
+	events, total, err := h.events.ListOutbox(r.Context(), pending, domain.OutboxEventType(r.URL.Query().Get("type")), limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list outbox events", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": events, "total": total, "limit": limit, "offset": offset})
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"kyd/internal/domain"
 	"kyd/internal/middleware"
 	"kyd/internal/payment"
+	pkgerrors "kyd/pkg/errors"
 	"kyd/pkg/validator"
 
 	"github.com/google/uuid"
@@ -43,6 +45,26 @@ func (h *PaymentHandler) InitiatePayment(w http.ResponseWriter, r *http.Request)
 	resp, err := h.service.InitiatePayment(r.Context(), &req)
 	if err != nil {
 		h.logger.Error("Payment initiation failed", map[string]interface{}{"error": err.Error(), "sender_id": userID})
+		if errors.Is(err, pkgerrors.ErrStepUpLocked) {
+			h.respondError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		if errors.Is(err, pkgerrors.ErrMovementFrozen) {
+			h.respondError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if errors.Is(err, pkgerrors.ErrJurisdictionRestricted) {
+			h.respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, payment.ErrDisclosureUnusable) || errors.Is(err, payment.ErrQuoteUnusable) {
+			h.respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, payment.ErrDisclosureNotFound) || errors.Is(err, payment.ErrQuoteNotFound) {
+			h.respondError(w, http.StatusNotFound, err.Error())
+			return
+		}
 		h.respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -52,8 +74,7 @@ func (h *PaymentHandler) InitiatePayment(w http.ResponseWriter, r *http.Request)
 
 // GetTransaction returns a single transaction by ID (for admin).
 func (h *PaymentHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -104,6 +125,30 @@ func (h *PaymentHandler) GetTransactionForUser(w http.ResponseWriter, r *http.Re
 	h.respondJSON(w, http.StatusOK, tx)
 }
 
+// GetTransactionByReference looks up one of the caller's transactions by the
+// reference number on its receipt. Admins may look up any transaction.
+func (h *PaymentHandler) GetTransactionByReference(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tx, err := h.service.GetTransactionByReference(r.Context(), mux.Vars(r)["reference"])
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+
+	if !middleware.HasPermission(r.Context(), domain.PermissionTransactionsRead) && tx.SenderID != userID && tx.ReceiverID != userID {
+		// Not found rather than forbidden, so references cannot be probed.
+		h.respondError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, tx)
+}
+
 // CancelPayment cancels a pending transaction (sender only).
 func (h *PaymentHandler) CancelPayment(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromContext(r.Context())
@@ -126,7 +171,7 @@ func (h *PaymentHandler) CancelPayment(w http.ResponseWriter, r *http.Request) {
 			h.respondError(w, http.StatusForbidden, "Unauthorized to cancel this transaction")
 			return
 		case strings.Contains(msg, "only pending"):
-			h.respondError(w, http.StatusBadRequest, "Only pending transactions can be cancelled")
+			h.respondError(w, http.StatusBadRequest, "Only pending or queued transactions can be cancelled")
 			return
 		default:
 			h.respondError(w, http.StatusNotFound, "Transaction not found")
@@ -170,8 +215,7 @@ func (h *PaymentHandler) BulkPayment(w http.ResponseWriter, r *http.Request) {
 
 // FlagTransaction flags a transaction for review.
 func (h *PaymentHandler) FlagTransaction(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -201,8 +245,7 @@ func (h *PaymentHandler) FlagTransaction(w http.ResponseWriter, r *http.Request)
 
 // ReverseTransaction reverses a transaction (admin-only).
 func (h *PaymentHandler) ReverseTransaction(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -271,6 +314,60 @@ func (h *PaymentHandler) GetTransactions(w http.ResponseWriter, r *http.Request)
 		walletID = &id
 	}
 
+	// Searching by the caller's own notes and tags.
+	if noteQuery, tag := r.URL.Query().Get("note"), r.URL.Query().Get("tag"); noteQuery != "" || tag != "" {
+		if walletID != nil || r.URL.Query().Has("cursor") {
+			h.respondError(w, http.StatusBadRequest, "Note search does not support wallet_id or cursor")
+			return
+		}
+		txs, total, err := h.service.SearchUserTransactions(r.Context(), userID, noteQuery, tag, limit, offset)
+		if err != nil {
+			h.respondNoteError(w, err)
+			return
+		}
+		h.respondJSON(w, http.StatusOK, map[string]interface{}{
+			"transactions": txs,
+			"total":        total,
+			"limit":        limit,
+			"offset":       offset,
+		})
+		return
+	}
+
+	// Clients that send a cursor (an empty one starts from the newest
+	// transaction) get keyset pages without a total.
+	if r.URL.Query().Has("cursor") {
+		if walletID != nil {
+			h.respondError(w, http.StatusBadRequest, "Cursor pagination is not supported with wallet_id")
+			return
+		}
+		var after *domain.TransactionCursor
+		if v := r.URL.Query().Get("cursor"); v != "" {
+			c, err := domain.ParseTransactionCursor(v)
+			if err != nil {
+				h.respondError(w, http.StatusBadRequest, "Invalid cursor")
+				return
+			}
+			after = c
+		}
+		txs, next, err := h.service.GetUserTransactionsAfter(r.Context(), userID, after, limit)
+		if err != nil {
+			h.logger.Error("Failed to fetch user transactions", map[string]interface{}{"error": err.Error()})
+			h.respondError(w, http.StatusInternalServerError, "Failed to fetch transactions")
+			return
+		}
+		var nextCursor string
+		if next != nil {
+			nextCursor = next.Encode()
+		}
+		h.respondJSON(w, http.StatusOK, map[string]interface{}{
+			"transactions": txs,
+			"limit":        limit,
+			"next_cursor":  nextCursor,
+		})
+		return
+	}
+
 	txs, total, err := h.service.GetUserTransactions(r.Context(), userID, walletID, limit, offset)
 	if err != nil {
 		h.logger.Error("Failed to fetch user transactions", map[string]interface{}{"error": err.Error()})
@@ -288,6 +385,11 @@ func (h *PaymentHandler) GetTransactions(w http.ResponseWriter, r *http.Request)
 
 // GetAllTransactions returns all transactions (for admin).
 func (h *PaymentHandler) GetAllTransactions(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
 	limit := 50
 	offset := 0
 	if v := r.URL.Query().Get("limit"); v != "" {
@@ -303,13 +405,24 @@ func (h *PaymentHandler) GetAllTransactions(w http.ResponseWriter, r *http.Reque
 
 	status := r.URL.Query().Get("status")
 	currency := r.URL.Query().Get("currency")
+	noteQuery := r.URL.Query().Get("note")
 
-	txs, total, err := h.service.GetAllTransactionsFiltered(r.Context(), limit, offset, status, currency)
+	var txs []*payment.TransactionDetail
+	var total int
+	var err error
+	if noteQuery != "" {
+		txs, total, err = h.service.SearchTransactionsByInternalNote(r.Context(), noteQuery, limit, offset)
+	} else {
+		txs, total, err = h.service.GetAllTransactionsFiltered(r.Context(), limit, offset, status, currency)
+	}
 	if err != nil {
 		h.logger.Error("Failed to fetch all transactions", map[string]interface{}{"error": err.Error()})
 		h.respondError(w, http.StatusInternalServerError, "Failed to fetch transactions")
 		return
 	}
+	if !seesUnmaskedData(r) {
+		txs = maskTransactionDetails(txs)
+	}
 
 	h.respondJSON(w, http.StatusOK, map[string]interface{}{
 		"transactions": txs,
@@ -341,6 +454,9 @@ func (h *PaymentHandler) GetPendingTransactions(w http.ResponseWriter, r *http.R
 		h.respondError(w, http.StatusInternalServerError, "Failed to fetch pending transactions")
 		return
 	}
+	if !seesUnmaskedData(r) {
+		txs = maskTransactionDetails(txs)
+	}
 
 	h.respondJSON(w, http.StatusOK, map[string]interface{}{
 		"transactions": txs,
@@ -409,6 +525,30 @@ func (h *PaymentHandler) GetReceipt(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, receipt)
 }
 
+// GetTimeline returns the milestones of a payment for its sender or receiver.
+func (h *PaymentHandler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	txID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+
+	timeline, err := h.service.GetTimeline(r.Context(), txID, userID)
+	if err != nil {
+		h.logger.Error("Failed to fetch timeline", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, timeline)
+}
+
 // InitiateDispute allows a user to dispute a transaction.
 func (h *PaymentHandler) InitiateDispute(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.UserIDFromContext(r.Context())
@@ -446,8 +586,7 @@ func (h *PaymentHandler) InitiateDispute(w http.ResponseWriter, r *http.Request)
 // GetTransactionVolume returns transaction volume analytics (for admin).
 func (h *PaymentHandler) GetTransactionVolume(w http.ResponseWriter, r *http.Request) {
 	// 1. Authorization Check (Admin only)
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -473,8 +612,7 @@ func (h *PaymentHandler) GetTransactionVolume(w http.ResponseWriter, r *http.Req
 }
 
 func (h *PaymentHandler) GetRiskUsageMetrics(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -492,8 +630,7 @@ func (h *PaymentHandler) GetRiskUsageMetrics(w http.ResponseWriter, r *http.Requ
 // GetSystemStats returns system-wide statistics (for admin).
 func (h *PaymentHandler) GetSystemStats(w http.ResponseWriter, r *http.Request) {
 	// 1. Authorization Check (Admin only)
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -513,8 +650,7 @@ func (h *PaymentHandler) GetSystemStats(w http.ResponseWriter, r *http.Request)
 // GetRiskAlerts returns flagged transactions (for admin/risk).
 func (h *PaymentHandler) GetRiskAlerts(w http.ResponseWriter, r *http.Request) {
 	// 1. Authorization Check (Admin only)
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -550,8 +686,7 @@ func (h *PaymentHandler) GetRiskAlerts(w http.ResponseWriter, r *http.Request) {
 // GetAuditLogs returns system audit logs (for admin).
 func (h *PaymentHandler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
 	// 1. Authorization Check (Admin only)
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -589,6 +724,11 @@ func (h *PaymentHandler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
 
 // GetDisputes returns all disputes (for admin).
 func (h *PaymentHandler) GetDisputes(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
 	limit := 50
 	offset := 0
 	if v := r.URL.Query().Get("limit"); v != "" {
@@ -619,6 +759,11 @@ func (h *PaymentHandler) GetDisputes(w http.ResponseWriter, r *http.Request) {
 
 // ResolveDispute handles admin resolution of disputes.
 func (h *PaymentHandler) ResolveDispute(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
 	adminID, ok := middleware.UserIDFromContext(r.Context())
 	if !ok {
 		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/paymentbatch"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// PaymentBatchHandler lets customers pay a file of payments at once and
+// follow each batch's progress.
+type PaymentBatchHandler struct {
+	service *paymentbatch.Service
+	logger  logger.Logger
+}
+
+func NewPaymentBatchHandler(service *paymentbatch.Service, log logger.Logger) *PaymentBatchHandler {
+	return &PaymentBatchHandler{service: service, logger: log}
+}
+
+// paymentBatchResponse adds a batch's progress to it.
+type paymentBatchResponse struct {
+	*domain.PaymentBatch
+	ValidItems int     `json:"valid_items"`
+	Progress   float64 `json:"progress"`
+}
+
+func newPaymentBatchResponse(b *domain.PaymentBatch) paymentBatchResponse {
+	return paymentBatchResponse{PaymentBatch: b, ValidItems: b.ValidItems(), Progress: b.Progress()}
+}
+
+// Upload takes a multipart form with the CSV or JSON file as "file" and,
+// optionally, the sender's "reference" for the batch. Item validation errors
+// and the totals to be paid come back at once; the valid items are paid in
+// the background.
+func (h *PaymentBatchHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		respondError(w, http.StatusBadRequest, "File too large or invalid form")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Missing file")
+		return
+	}
+	defer file.Close()
+
+	b, items, err := h.service.Upload(r.Context(), &paymentbatch.UploadRequest{
+		UserID:    userID,
+		FileName:  header.Filename,
+		Reference: r.FormValue("reference"),
+		Content:   file,
+	})
+	if err != nil {
+		h.respondBatchError(w, err)
+		return
+	}
+
+	type itemErrors struct {
+		Line   int                       `json:"line"`
+		Errors domain.PaymentBatchErrors `json:"errors"`
+	}
+	invalid := make([]itemErrors, 0, b.InvalidItems)
+	totals := make(map[string]decimal.Decimal)
+	for _, item := range items {
+		if item.Status == domain.PaymentBatchItemInvalid {
+			invalid = append(invalid, itemErrors{Line: item.Line, Errors: item.Errors})
+			continue
+		}
+		amount, _ := decimal.NewFromString(item.Record.Amount)
+		totals[item.Record.Currency] = totals[item.Record.Currency].Add(amount)
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"batch":   newPaymentBatchResponse(b),
+		"totals":  totals,
+		"invalid": invalid,
+	})
+}
+
+// List returns the caller's batches, newest first.
+func (h *PaymentBatchHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	limit, offset := parsePagination(r)
+	batches, total, err := h.service.List(r.Context(), userID, limit, offset)
+	if err != nil {
+		h.respondBatchError(w, err)
+		return
+	}
+	items := make([]paymentBatchResponse, len(batches))
+	for i := range batches {
+		items[i] = newPaymentBatchResponse(&batches[i])
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Get returns one of the caller's batches and its progress.
+func (h *PaymentBatchHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid batch ID")
+		return
+	}
+	b, err := h.service.Get(r.Context(), userID, id)
+	if err != nil {
+		h.respondBatchError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, newPaymentBatchResponse(b))
+}
+
+// Items returns a batch's per-item results, optionally filtered by status.
+func (h *PaymentBatchHandler) Items(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid batch ID")
+		return
+	}
+	status := domain.PaymentBatchItemStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", domain.PaymentBatchItemInvalid, domain.PaymentBatchItemPending, domain.PaymentBatchItemSucceeded, domain.PaymentBatchItemFailed:
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.Items(r.Context(), userID, id, status, limit, offset)
+	if err != nil {
+		h.respondBatchError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Report downloads the outcome of every item of a batch as CSV.
+func (h *PaymentBatchHandler) Report(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid batch ID")
+		return
+	}
+	// Built in memory first, so a failure halfway still gets a proper
+	// error response
+	var buf bytes.Buffer
+	if _, err := h.service.Report(r.Context(), userID, id, &buf); err != nil {
+		h.respondBatchError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "payment-batch-"+id.String()+"-report.csv"))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (h *PaymentBatchHandler) respondBatchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, paymentbatch.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Payment batch not found")
+	case errors.Is(err, paymentbatch.ErrInvalidFile):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, paymentbatch.ErrReferenceExists):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Payment batch request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
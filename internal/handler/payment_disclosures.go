@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateDisclosure discloses a payment's total cost, exchange rate and
+// delivery date before it is made. Passing the disclosure's ID as
+// disclosure_id when initiating the payment holds the payment to it.
+func (h *PaymentHandler) CreateDisclosure(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req payment.DisclosureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.SenderID = userID
+	d, err := h.service.Disclose(r.Context(), &req)
+	if err != nil {
+		h.respondDisclosureError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, d)
+}
+
+// GetDisclosure returns one of the caller's disclosures.
+func (h *PaymentHandler) GetDisclosure(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid disclosure ID")
+		return
+	}
+	d, err := h.service.GetDisclosure(r.Context(), userID, id)
+	if err != nil {
+		h.respondDisclosureError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, d)
+}
+
+// GetTransactionDisclosure returns the disclosure a payment was made under
+// (for admin).
+func (h *PaymentHandler) GetTransactionDisclosure(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.transactionDisclosure(w, r, uuid.Nil)
+}
+
+// GetTransactionDisclosureForUser returns the disclosure a payment was made
+// under to its sender.
+func (h *PaymentHandler) GetTransactionDisclosureForUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	h.transactionDisclosure(w, r, userID)
+}
+
+func (h *PaymentHandler) transactionDisclosure(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	txID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	d, err := h.service.TransactionDisclosure(r.Context(), txID, userID)
+	if err != nil {
+		h.respondDisclosureError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, d)
+}
+
+func (h *PaymentHandler) respondDisclosureError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, payment.ErrInvalidDisclosure):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, payment.ErrDisclosureNotFound):
+		h.respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, pkgerrors.ErrWalletNotFound):
+		h.respondError(w, http.StatusNotFound, "Wallet not found")
+	case errors.Is(err, payment.ErrDisclosuresDisabled):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	default:
+		h.logger.Error("Payment disclosure request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process disclosure")
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+)
+
+// QuoteFee returns what a payment would be charged and how the fee is made
+// up, before the sender confirms it.
+func (h *PaymentHandler) QuoteFee(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req payment.FeeQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.SenderID = userID
+	quote, err := h.service.QuoteFee(r.Context(), &req)
+	if err != nil {
+		if errors.Is(err, payment.ErrInvalidFeeQuote) {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.logger.Error("Fee quote failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to quote fee")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, quote)
+}
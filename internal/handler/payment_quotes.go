@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	pkgerrors "kyd/pkg/errors"
+)
+
+// CreateQuote prices a payment and locks its exchange rate for a short
+// while. Passing the quote's ID as quote_id when initiating the payment
+// converts it at the quoted rate.
+func (h *PaymentHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req payment.QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.SenderID = userID
+	q, err := h.service.Quote(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, payment.ErrInvalidQuote):
+			h.respondError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, pkgerrors.ErrWalletNotFound):
+			h.respondError(w, http.StatusNotFound, "Wallet not found")
+		case errors.Is(err, payment.ErrQuotesDisabled):
+			h.respondError(w, http.StatusNotImplemented, err.Error())
+		default:
+			h.logger.Error("Payment quote failed", map[string]interface{}{"error": err.Error()})
+			h.respondError(w, http.StatusInternalServerError, "Failed to quote payment")
+		}
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, q)
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kyd/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisputes_RequireAdmin(t *testing.T) {
+	// No payment service: reaching it would panic.
+	h := NewPaymentHandler(nil, nil, logger.NewNop())
+
+	for _, claims := range []jwt.MapClaims{{"user_type": "individual"}, {"user_type": "merchant"}, {}} {
+		rec := authenticated(t, h.GetDisputes, claims, httptest.NewRequest(http.MethodGet, "/admin/disputes", nil))
+		assert.Equal(t, http.StatusForbidden, rec.Code, "list as %v", claims["user_type"])
+
+		body := `{"transaction_id":"00000000-0000-0000-0000-000000000001","resolution":"refund"}`
+		rec = authenticated(t, h.ResolveDispute, claims, httptest.NewRequest(http.MethodPost, "/admin/disputes/resolve", strings.NewReader(body)))
+		assert.Equal(t, http.StatusForbidden, rec.Code, "resolve as %v", claims["user_type"])
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/plans"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PlansHandler serves the plan catalog and plan changes.
+type PlansHandler struct {
+	service *plans.Service
+	logger  logger.Logger
+}
+
+func NewPlansHandler(service *plans.Service, log logger.Logger) *PlansHandler {
+	return &PlansHandler{service: service, logger: log}
+}
+
+type planChangeRequest struct {
+	Plan domain.PlanCode `json:"plan"`
+}
+
+type myPlanResponse struct {
+	Subscription *domain.PlanSubscription `json:"subscription"`
+	Entitlements *domain.Entitlements     `json:"entitlements"`
+}
+
+// List returns the plan catalog.
+func (h *PlansHandler) List(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{"plans": h.service.Plans()})
+}
+
+// Mine returns the caller's subscription and what it entitles them to.
+func (h *PlansHandler) Mine(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	h.respondPlan(w, r, userID, nil)
+}
+
+// Change upgrades the caller at once, prorated, or schedules a downgrade for
+// the end of the billing period.
+func (h *PlansHandler) Change(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req planChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	sub, err := h.service.ChangePlan(r.Context(), userID, req.Plan)
+	if err != nil {
+		h.respondPlanError(w, err)
+		return
+	}
+	h.respondPlan(w, r, userID, sub)
+}
+
+// Assign puts a user on a plan at once (admin).
+func (h *PlansHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	var req planChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	sub, err := h.service.AssignPlan(r.Context(), userID, req.Plan, adminID)
+	if err != nil {
+		h.respondPlanError(w, err)
+		return
+	}
+	h.respondPlan(w, r, userID, sub)
+}
+
+func (h *PlansHandler) respondPlan(w http.ResponseWriter, r *http.Request, userID uuid.UUID, sub *domain.PlanSubscription) {
+	var err error
+	if sub == nil {
+		if sub, err = h.service.Subscription(r.Context(), userID); err != nil {
+			h.respondPlanError(w, err)
+			return
+		}
+	}
+	ent, err := h.service.Entitlements(r.Context(), userID)
+	if err != nil {
+		h.respondPlanError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, myPlanResponse{Subscription: sub, Entitlements: ent})
+}
+
+func (h *PlansHandler) respondPlanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, plans.ErrUnknownPlan):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, plans.ErrPlanNotAvailable):
+		respondError(w, http.StatusForbidden, err.Error())
+	default:
+		h.logger.Error("Plan request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process plan")
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/reconciliation"
+	"kyd/pkg/logger"
+)
+
+// ReconciliationHandler exposes the breaks found by the scheduled
+// reconciliation of wallets against the ledger to admins.
+type ReconciliationHandler struct {
+	service *reconciliation.Service
+	logger  logger.Logger
+}
+
+func NewReconciliationHandler(service *reconciliation.Service, log logger.Logger) *ReconciliationHandler {
+	return &ReconciliationHandler{service: service, logger: log}
+}
+
+// ListBreaks returns reconciliation breaks, optionally filtered by check and
+// status (open by default, or resolved or all) (admin).
+func (h *ReconciliationHandler) ListBreaks(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	q := r.URL.Query()
+	check := domain.ReconciliationCheck(q.Get("check"))
+	status := domain.ReconciliationBreakStatus(q.Get("status"))
+	items, total, err := h.service.ListBreaks(r.Context(), check, status, limit, offset)
+	if err != nil {
+		h.respondReconciliationError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// Run reconciles now and returns the run (admin).
+func (h *ReconciliationHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	run, err := h.service.Run(r.Context())
+	if err != nil {
+		h.respondReconciliationError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
+
+func (h *ReconciliationHandler) respondReconciliationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, reconciliation.ErrRunInProgress):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Reconciliation request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/recovery"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// RecoveryHandler exposes the exception queue of stuck transactions that
+// automated recovery escalated to admins.
+type RecoveryHandler struct {
+	service *recovery.Service
+	logger  logger.Logger
+}
+
+func NewRecoveryHandler(service *recovery.Service, log logger.Logger) *RecoveryHandler {
+	return &RecoveryHandler{service: service, logger: log}
+}
+
+// ListExceptions returns escalated transactions, optionally filtered by
+// status (open by default, or resolved or all) and reason (admin).
+func (h *RecoveryHandler) ListExceptions(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	q := r.URL.Query()
+	status := domain.TransactionExceptionStatus(q.Get("status"))
+	reason := domain.StuckReason(q.Get("reason"))
+	items, total, err := h.service.ListExceptions(r.Context(), status, reason, limit, offset)
+	if err != nil {
+		h.respondRecoveryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// ResolveException closes an exception with the admin's resolution (admin).
+func (h *RecoveryHandler) ResolveException(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid exception ID")
+		return
+	}
+	var req struct {
+		Resolution string `json:"resolution"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	e, err := h.service.ResolveException(r.Context(), id, adminID, req.Resolution)
+	if err != nil {
+		h.respondRecoveryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, e)
+}
+
+// Run runs stuck transaction recovery now and returns what it did (admin).
+func (h *RecoveryHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	run, err := h.service.Run(r.Context())
+	if err != nil {
+		h.respondRecoveryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
+
+func (h *RecoveryHandler) respondRecoveryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, recovery.ErrResolutionRequired):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, recovery.ErrExceptionNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, recovery.ErrRunInProgress):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Recovery request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
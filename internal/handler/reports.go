@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/export"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ReportsHandler lets merchants generate settlement and fee reports of their
+// own transactions, on demand or daily, and fetch them through signed links.
+type ReportsHandler struct {
+	service *export.Service
+	logger  logger.Logger
+}
+
+func NewReportsHandler(service *export.Service, log logger.Logger) *ReportsHandler {
+	return &ReportsHandler{service: service, logger: log}
+}
+
+func (h *ReportsHandler) merchant(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	return requireMerchant(w, r, "Reports are available to merchant accounts")
+}
+
+// List returns the caller's reports, newest first.
+func (h *ReportsHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	limit, offset := parsePagination(r)
+	jobs, total, err := h.service.ListReports(r.Context(), userID, limit, offset)
+	if err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+	items := make([]exportJobResponse, 0, len(jobs))
+	for i := range jobs {
+		items = append(items, newExportJobResponse(h.service, &jobs[i]))
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Create queues a report and returns immediately; poll Get for the link.
+func (h *ReportsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	var req export.ReportRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	j, err := h.service.SubmitReport(r.Context(), userID, req, userID)
+	if err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/reports/"+j.ID.String())
+	respondJSON(w, http.StatusAccepted, newExportJobResponse(h.service, j))
+}
+
+// Get reports progress and, once complete, a signed download link.
+func (h *ReportsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+	j, err := h.service.GetReport(r.Context(), userID, id)
+	if err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, newExportJobResponse(h.service, j))
+}
+
+// ListSchedules returns the caller's daily reports.
+func (h *ReportsHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.listSchedules(w, r, &userID)
+}
+
+// CreateSchedule sets up a daily report for the caller.
+func (h *ReportsHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	var req export.ScheduleRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	sch, err := h.service.CreateSchedule(r.Context(), userID, req, userID)
+	if err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, sch)
+}
+
+// DeleteSchedule stops one of the caller's daily reports.
+func (h *ReportsHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.deleteSchedule(w, r, &userID)
+}
+
+// AdminListSchedules returns every daily report, or one owner's with
+// ?owner_id= (admin).
+func (h *ReportsHandler) AdminListSchedules(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var owner *uuid.UUID
+	if v := r.URL.Query().Get("owner_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid owner ID")
+			return
+		}
+		owner = &id
+	}
+	h.listSchedules(w, r, owner)
+}
+
+// AdminDeleteSchedule stops any daily report (admin).
+func (h *ReportsHandler) AdminDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.deleteSchedule(w, r, nil)
+}
+
+func (h *ReportsHandler) listSchedules(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	schedules, err := h.service.ListSchedules(r.Context(), owner)
+	if err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"schedules": schedules})
+}
+
+func (h *ReportsHandler) deleteSchedule(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+	if err := h.service.DeleteSchedule(r.Context(), owner, id); err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ReportsHandler) respondReportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, export.ErrUnknownKind):
+		respondError(w, http.StatusBadRequest, "Unknown report kind: use settlement_report or fee_report")
+	case errors.Is(err, export.ErrInvalidFilters):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, export.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Report not found")
+	case errors.Is(err, export.ErrScheduleNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, export.ErrScheduleExists):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Report request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process report request")
+	}
+}
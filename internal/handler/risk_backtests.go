@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/riskbacktest"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// RiskBacktestHandler lets compliance see how candidate risk rules would
+// have treated past payments before putting them live.
+type RiskBacktestHandler struct {
+	service *riskbacktest.Service
+	logger  logger.Logger
+}
+
+func NewRiskBacktestHandler(service *riskbacktest.Service, log logger.Logger) *RiskBacktestHandler {
+	return &RiskBacktestHandler{service: service, logger: log}
+}
+
+// Rules returns the live risk rules, which candidates change (admin).
+func (h *RiskBacktestHandler) Rules(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	respondJSON(w, http.StatusOK, h.service.LiveRules())
+}
+
+// Create queues a backtest of candidate rules over a past window (admin).
+func (h *RiskBacktestHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req riskbacktest.Request
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	b, err := h.service.Create(r.Context(), req, adminID)
+	if err != nil {
+		h.respondBacktestError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/admin/risk/backtests/"+b.ID.String())
+	respondJSON(w, http.StatusAccepted, b)
+}
+
+// List returns backtests, newest first (admin).
+func (h *RiskBacktestHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.List(r.Context(), limit, offset)
+	if err != nil {
+		h.respondBacktestError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "limit": limit, "offset": offset})
+}
+
+// Get returns a backtest with its results once completed (admin).
+func (h *RiskBacktestHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid backtest ID")
+		return
+	}
+	b, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondBacktestError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, b)
+}
+
+func (h *RiskBacktestHandler) respondBacktestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, riskbacktest.ErrInvalidBacktest):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, riskbacktest.ErrBacktestNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	default:
+		h.logger.Error("Risk backtest request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process risk backtest request")
+	}
+}
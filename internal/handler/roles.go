@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/rbac"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type RolesHandler struct {
+	service *rbac.Service
+	logger  logger.Logger
+}
+
+func NewRolesHandler(service *rbac.Service, log logger.Logger) *RolesHandler {
+	return &RolesHandler{service: service, logger: log}
+}
+
+// List returns every role with the permissions it grants.
+func (h *RolesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	roles, err := h.service.Roles(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list roles", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to list roles")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"roles": roles})
+}
+
+// Mine returns the caller's own permissions, so a console can hide what
+// they may not use.
+func (h *RolesHandler) Mine(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	perms := make([]domain.Permission, 0)
+	for p := range middleware.PermissionsFromContext(r.Context()) {
+		perms = append(perms, p)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	respondJSON(w, http.StatusOK, map[string]interface{}{"permissions": perms})
+}
+
+// UserRoles returns the roles a user holds.
+func (h *RolesHandler) UserRoles(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	roles, err := h.service.UserRoles(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to list user roles", map[string]interface{}{"user_id": userID, "error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to list user roles")
+		return
+	}
+	if roles == nil {
+		roles = []domain.RoleAssignment{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"roles": roles})
+}
+
+// Assign grants a role to a user.
+func (h *RolesHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.service.Assign)
+}
+
+// Revoke takes a role away from a user.
+func (h *RolesHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, h.service.Revoke)
+}
+
+func (h *RolesHandler) change(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, userID uuid.UUID, role domain.Role, actorID uuid.UUID) error) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	actorID, _ := middleware.UserIDFromContext(r.Context())
+	vars := mux.Vars(r)
+	userID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	role := domain.Role(vars["role"])
+
+	err = apply(r.Context(), userID, role, actorID)
+	switch {
+	case errors.Is(err, rbac.ErrUnknownRole):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, rbac.ErrUnknownUser), errors.Is(err, rbac.ErrNotAssigned):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, rbac.ErrLastAdmin):
+		respondError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		h.logger.Error("Failed to change user role", map[string]interface{}{"user_id": userID, "role": role, "error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to change user role")
+	default:
+		respondJSON(w, http.StatusOK, map[string]interface{}{"user_id": userID, "role": role})
+	}
+}
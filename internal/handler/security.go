@@ -26,8 +26,7 @@ func NewSecurityHandler(service *security.Service, val *validator.Validator) *Se
 }
 
 func (h *SecurityHandler) GetSecurityEvents(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -89,8 +88,7 @@ func parseSecurityEventFilter(r *http.Request) (*security.EventFilter, error) {
 }
 
 func (h *SecurityHandler) UpdateSecurityEvent(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -121,8 +119,7 @@ func (h *SecurityHandler) UpdateSecurityEvent(w http.ResponseWriter, r *http.Req
 }
 
 func (h *SecurityHandler) GetBlocklist(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -137,8 +134,7 @@ func (h *SecurityHandler) GetBlocklist(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *SecurityHandler) AddToBlocklist(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -175,8 +171,7 @@ func (h *SecurityHandler) AddToBlocklist(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *SecurityHandler) RemoveFromBlocklist(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -197,8 +192,7 @@ func (h *SecurityHandler) RemoveFromBlocklist(w http.ResponseWriter, r *http.Req
 }
 
 func (h *SecurityHandler) GetSystemHealth(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -213,8 +207,7 @@ func (h *SecurityHandler) GetSystemHealth(w http.ResponseWriter, r *http.Request
 }
 
 func (h *SecurityHandler) GetRiskConfig(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -230,8 +223,7 @@ func (h *SecurityHandler) GetRiskConfig(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *SecurityHandler) GetRiskStatus(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -247,8 +239,7 @@ func (h *SecurityHandler) GetRiskStatus(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *SecurityHandler) UpdateRiskConfig(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
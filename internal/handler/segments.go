@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/segments"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SegmentsHandler serves saved user segments and the feature flags that
+// target them.
+type SegmentsHandler struct {
+	service *segments.Service
+	logger  logger.Logger
+}
+
+func NewSegmentsHandler(service *segments.Service, log logger.Logger) *SegmentsHandler {
+	return &SegmentsHandler{service: service, logger: log}
+}
+
+// List returns every segment (admin).
+func (h *SegmentsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	segs, err := h.service.List(r.Context())
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"segments": segs})
+}
+
+// Create saves a segment (admin).
+func (h *SegmentsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	var req segments.SegmentRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	seg, err := h.service.Create(r.Context(), req, adminID)
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, seg)
+}
+
+// Get returns a segment with its current member count (admin).
+func (h *SegmentsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid segment ID")
+		return
+	}
+	seg, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	n, err := h.service.Preview(r.Context(), seg.Criteria)
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"segment": seg, "members": n})
+}
+
+// Update replaces a segment (admin).
+func (h *SegmentsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid segment ID")
+		return
+	}
+	var req segments.SegmentRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	seg, err := h.service.Update(r.Context(), id, req)
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, seg)
+}
+
+// Delete removes a segment no feature flag uses (admin).
+func (h *SegmentsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid segment ID")
+		return
+	}
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preview counts the users a set of criteria matches (admin).
+func (h *SegmentsHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var criteria domain.SegmentCriteria
+	if err := decodeStrict(w, r, &criteria); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	n, err := h.service.Preview(r.Context(), criteria)
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]int{"members": n})
+}
+
+// ListFlags returns every feature flag (admin).
+func (h *SegmentsHandler) ListFlags(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	flags, err := h.service.ListFlags(r.Context())
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"flags": flags})
+}
+
+// SetFlag creates or replaces a feature flag (admin).
+func (h *SegmentsHandler) SetFlag(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	var req segments.FlagRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	f, err := h.service.SetFlag(r.Context(), mux.Vars(r)["key"], req, adminID)
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, f)
+}
+
+// DeleteFlag removes a feature flag (admin).
+func (h *SegmentsHandler) DeleteFlag(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	if err := h.service.DeleteFlag(r.Context(), mux.Vars(r)["key"]); err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Features returns which feature flags are on for the caller.
+func (h *SegmentsHandler) Features(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	features, err := h.service.Features(r.Context(), userID)
+	if err != nil {
+		h.respondSegmentError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"features": features})
+}
+
+func (h *SegmentsHandler) respondSegmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, segments.ErrSegmentNotFound), errors.Is(err, segments.ErrFlagNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, segments.ErrInvalidSegment), errors.Is(err, segments.ErrInvalidFlag):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, segments.ErrSegmentExists), errors.Is(err, segments.ErrSegmentInUse):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Segment request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process segment request")
+	}
+}
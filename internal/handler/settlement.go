@@ -6,7 +6,6 @@ import (
 	"strconv"
 	"time"
 
-	"kyd/internal/middleware"
 	"kyd/internal/settlement"
 	"kyd/pkg/logger"
 
@@ -28,8 +27,7 @@ func NewSettlementHandler(service *settlement.Service, log logger.Logger) *Settl
 
 func (h *SettlementHandler) ListSettlements(w http.ResponseWriter, r *http.Request) {
 	// Admin check
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -68,8 +66,7 @@ func (h *SettlementHandler) ListSettlements(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *SettlementHandler) GetSettlement(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -88,9 +85,33 @@ func (h *SettlementHandler) GetSettlement(w http.ResponseWriter, r *http.Request
 	h.respondJSON(w, http.StatusOK, set)
 }
 
+// GetSettlementNetting returns the audit of the net settlement window a
+// settlement was made from: the corridor's position and every payment netted.
+func (h *SettlementHandler) GetSettlementNetting(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid settlement id")
+		return
+	}
+	netting, err := h.service.GetNetting(r.Context(), id)
+	if err == settlement.ErrNettingNotFound {
+		h.respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to fetch settlement netting", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "failed to fetch settlement netting")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, netting)
+}
+
 func (h *SettlementHandler) RetrySettlement(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -100,6 +121,10 @@ func (h *SettlementHandler) RetrySettlement(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	set, err := h.service.RetrySettlement(r.Context(), id)
+	if err == settlement.ErrSubmissionPaused {
+		h.respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
 	if err != nil {
 		h.respondError(w, http.StatusBadRequest, "failed to retry settlement")
 		return
@@ -108,8 +133,7 @@ func (h *SettlementHandler) RetrySettlement(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *SettlementHandler) ReconcileSettlement(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -131,10 +155,62 @@ func (h *SettlementHandler) ReconcileSettlement(w http.ResponseWriter, r *http.R
 	h.respondJSON(w, http.StatusOK, map[string]interface{}{"settlement": set})
 }
 
+// GetNetworkFeeReport returns on-chain fees paid per network for cost reporting.
+// The window defaults to the last 30 days and can be set with RFC3339 from/to.
+func (h *SettlementHandler) GetNetworkFeeReport(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid from timestamp")
+			return
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid to timestamp")
+			return
+		}
+		to = t
+	}
+
+	items, err := h.service.NetworkFeeReport(r.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Failed to build network fee report", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to build network fee report")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"from":  from,
+		"to":    to,
+	})
+}
+
+// GetThrottleStatus reports whether each settlement network is accepting,
+// slowing or pausing submissions and why.
+func (h *SettlementHandler) GetThrottleStatus(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": h.service.ThrottleStatus(r.Context()),
+	})
+}
+
 func (h *SettlementHandler) GetBankAccounts(w http.ResponseWriter, r *http.Request) {
 	// Admin check
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -178,8 +254,7 @@ func (h *SettlementHandler) GetBankAccounts(w http.ResponseWriter, r *http.Reque
 
 func (h *SettlementHandler) GetPaymentGateways(w http.ResponseWriter, r *http.Request) {
 	// Admin check
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/settlement"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// SettlementRouteHandler exposes the corridor-to-rail routing table to admins.
+type SettlementRouteHandler struct {
+	router *settlement.Router
+	logger logger.Logger
+}
+
+func NewSettlementRouteHandler(router *settlement.Router, log logger.Logger) *SettlementRouteHandler {
+	return &SettlementRouteHandler{router: router, logger: log}
+}
+
+type settlementRouteRequest struct {
+	Corridor      string           `json:"corridor"`
+	MinAmount     decimal.Decimal  `json:"min_amount"`
+	MaxAmount     *decimal.Decimal `json:"max_amount"`
+	Network       string           `json:"network"`
+	EffectiveFrom *time.Time       `json:"effective_from"`
+	EffectiveTo   *time.Time       `json:"effective_to"`
+	Notes         *string          `json:"notes"`
+}
+
+// toRoute builds a route from the request. A missing effective_from means
+// the route takes effect immediately.
+func (req *settlementRouteRequest) toRoute() *domain.SettlementRoute {
+	rt := &domain.SettlementRoute{
+		Corridor:    req.Corridor,
+		MinAmount:   req.MinAmount,
+		MaxAmount:   req.MaxAmount,
+		Network:     domain.BlockchainNetwork(strings.ToLower(strings.TrimSpace(req.Network))),
+		EffectiveTo: req.EffectiveTo,
+		Notes:       req.Notes,
+	}
+	if req.EffectiveFrom != nil {
+		rt.EffectiveFrom = *req.EffectiveFrom
+	} else {
+		rt.EffectiveFrom = time.Now()
+	}
+	return rt
+}
+
+// isAdminRequest reports whether the caller is staff. Which staff may use
+// an endpoint is decided by the permission checks on its route.
+func isAdminRequest(r *http.Request) bool {
+	return middleware.IsStaff(r.Context())
+}
+
+func decodeStrict(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func (h *SettlementRouteHandler) respondRouteError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, settlement.ErrInvalidRoute), errors.Is(err, settlement.ErrUnsupportedRail):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, settlement.ErrRouteConflict), errors.Is(err, settlement.ErrRouteImmutable):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, settlement.ErrRouteNotFound):
+		respondError(w, http.StatusNotFound, "Settlement route not found")
+	default:
+		h.logger.Error("Failed to "+action+" settlement route", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to "+action+" settlement route")
+	}
+}
+
+func (h *SettlementRouteHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	items, err := h.router.ListRoutes(r.Context(), r.URL.Query().Get("corridor"))
+	if err != nil {
+		h.respondRouteError(w, err, "list")
+		return
+	}
+	if items == nil {
+		items = []*domain.SettlementRoute{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+func (h *SettlementRouteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req settlementRouteRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rt := req.toRoute()
+	if actorID, ok := middleware.UserIDFromContext(r.Context()); ok {
+		rt.CreatedBy = &actorID
+	}
+	created, err := h.router.CreateRoute(r.Context(), rt)
+	if err != nil {
+		h.respondRouteError(w, err, "create")
+		return
+	}
+	respondJSON(w, http.StatusCreated, created)
+}
+
+func (h *SettlementRouteHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid route ID")
+		return
+	}
+	rt, err := h.router.GetRoute(r.Context(), id)
+	if err != nil {
+		h.respondRouteError(w, err, "get")
+		return
+	}
+	respondJSON(w, http.StatusOK, rt)
+}
+
+func (h *SettlementRouteHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid route ID")
+		return
+	}
+	var req settlementRouteRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.EffectiveFrom == nil {
+		respondError(w, http.StatusBadRequest, "effective_from is required")
+		return
+	}
+
+	rt := req.toRoute()
+	rt.ID = id
+	updated, err := h.router.UpdateRoute(r.Context(), rt)
+	if err != nil {
+		h.respondRouteError(w, err, "update")
+		return
+	}
+	respondJSON(w, http.StatusOK, updated)
+}
+
+func (h *SettlementRouteHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid route ID")
+		return
+	}
+	if err := h.router.DeleteRoute(r.Context(), id); err != nil {
+		h.respondRouteError(w, err, "delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Resolve shows which rail a corridor and amount would settle on, now or at
+// the RFC3339 time given in "at".
+func (h *SettlementRouteHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	q := r.URL.Query()
+	corridor := strings.ToUpper(strings.TrimSpace(q.Get("corridor")))
+	if corridor == "" {
+		respondError(w, http.StatusBadRequest, "corridor is required")
+		return
+	}
+	amount, err := decimal.NewFromString(q.Get("amount"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid amount")
+		return
+	}
+	at := time.Now()
+	if v := q.Get("at"); v != "" {
+		if at, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid at timestamp")
+			return
+		}
+	}
+
+	decision, err := h.router.Resolve(r.Context(), corridor, amount, at)
+	if err != nil {
+		h.respondRouteError(w, err, "resolve")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"corridor": corridor,
+		"amount":   amount,
+		"at":       at,
+		"network":  decision.Network,
+		"route_id": decision.RouteID,
+		"reason":   decision.Reason,
+	})
+}
+
+// Simulate replays a day's transactions through the routing table and reports
+// how their volume would have been split across rails. The day defaults to
+// yesterday (UTC). Supplying "routes" simulates a draft table instead of the
+// stored one; supplying "at" evaluates every transaction as of that moment,
+// which previews a scheduled switchover against real volume.
+func (h *SettlementRouteHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var req struct {
+		Date   string                    `json:"date"`
+		At     *time.Time                `json:"at"`
+		Routes []*settlementRouteRequest `json:"routes"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeStrict(w, r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	if req.Date != "" {
+		d, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+			return
+		}
+		from = d
+	}
+	to := from.AddDate(0, 0, 1)
+
+	var at time.Time
+	if req.At != nil {
+		at = *req.At
+	}
+	var draft []*domain.SettlementRoute
+	if req.Routes != nil {
+		draft = make([]*domain.SettlementRoute, 0, len(req.Routes))
+		for _, rr := range req.Routes {
+			rt := rr.toRoute()
+			if rr.EffectiveFrom == nil {
+				rt.EffectiveFrom = from
+			}
+			rt.ID = uuid.New()
+			draft = append(draft, rt)
+		}
+	}
+
+	sim, err := h.router.Simulate(r.Context(), from, to, at, draft)
+	if err != nil {
+		h.respondRouteError(w, err, "simulate")
+		return
+	}
+	respondJSON(w, http.StatusOK, sim)
+}
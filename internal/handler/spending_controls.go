@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+
+	"github.com/shopspring/decimal"
+)
+
+// GetSpendingControls returns the caller's own spending controls.
+func (h *PaymentHandler) GetSpendingControls(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	controls, err := h.service.GetSpendingControls(r.Context(), userID)
+	if err != nil {
+		h.respondSpendingControlsError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	h.respondJSON(w, http.StatusOK, controls)
+}
+
+// UpdateSpendingControls replaces the caller's spending controls. Omitted or
+// null limits are removed. The next payment is checked against the new
+// controls, and the caller is notified of the change.
+func (h *PaymentHandler) UpdateSpendingControls(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		MaxPerTransaction *decimal.Decimal `json:"max_per_transaction"`
+		DailyCap          *decimal.Decimal `json:"daily_cap"`
+		BlockCrossBorder  bool             `json:"block_cross_border"`
+		BlockedChannels   []string         `json:"blocked_channels"`
+		Frozen            bool             `json:"frozen"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	controls, err := h.service.UpdateSpendingControls(r.Context(), userID, domain.SpendingControls{
+		MaxPerTransaction: req.MaxPerTransaction,
+		DailyCap:          req.DailyCap,
+		BlockCrossBorder:  req.BlockCrossBorder,
+		BlockedChannels:   req.BlockedChannels,
+		Frozen:            req.Frozen,
+	})
+	if err != nil {
+		h.respondSpendingControlsError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, controls)
+}
+
+func (h *PaymentHandler) respondSpendingControlsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, payment.ErrInvalidSpendingControls):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, payment.ErrSpendingControlsDisabled):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	default:
+		h.logger.Error("Spending controls request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process spending controls")
+	}
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/standing"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// StandingInstructionHandler lets customers manage their standing
+// instructions on incoming funds and the savings pockets they sweep into.
+type StandingInstructionHandler struct {
+	service   *standing.Service
+	validator *validator.Validator
+	logger    logger.Logger
+}
+
+func NewStandingInstructionHandler(service *standing.Service, val *validator.Validator, log logger.Logger) *StandingInstructionHandler {
+	return &StandingInstructionHandler{service: service, validator: val, logger: log}
+}
+
+// List returns the caller's instructions.
+func (h *StandingInstructionHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	items, err := h.service.Instructions(r.Context(), userID)
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// Create sets up an instruction on one of the caller's wallets.
+func (h *StandingInstructionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req standing.CreateRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	in, err := h.service.Create(r.Context(), userID, &req)
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, in)
+}
+
+// Update changes the threshold of the caller's instruction, or turns it on
+// or off.
+func (h *StandingInstructionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid instruction ID")
+		return
+	}
+	var req standing.UpdateRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	in, err := h.service.Update(r.Context(), userID, id, &req)
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, in)
+}
+
+// Delete removes the caller's instruction.
+func (h *StandingInstructionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid instruction ID")
+		return
+	}
+	if err := h.service.Delete(r.Context(), userID, id); err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Executions returns what the caller's instruction did, newest first.
+func (h *StandingInstructionHandler) Executions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid instruction ID")
+		return
+	}
+	limit, offset := parsePagination(r)
+	items, total, err := h.service.Executions(r.Context(), userID, id, limit, offset)
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Pockets returns the caller's savings pockets.
+func (h *StandingInstructionHandler) Pockets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	items, err := h.service.Pockets(r.Context(), userID)
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// CreatePocket opens a savings pocket in one of the caller's wallets.
+func (h *StandingInstructionHandler) CreatePocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req standing.CreatePocketRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	p, err := h.service.CreatePocket(r.Context(), userID, &req)
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, p)
+}
+
+// WithdrawFromPocket gives money in the caller's pocket back to its wallet.
+func (h *StandingInstructionHandler) WithdrawFromPocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid pocket ID")
+		return
+	}
+	var req struct {
+		Amount decimal.Decimal `json:"amount"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	p, err := h.service.WithdrawFromPocket(r.Context(), userID, id, req.Amount)
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, p)
+}
+
+// Run acts on the credits that have settled since the last run and returns
+// what it did (admin).
+func (h *StandingInstructionHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	run, err := h.service.Run(r.Context())
+	if err != nil {
+		h.respondStandingError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
+
+func (h *StandingInstructionHandler) respondStandingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, standing.ErrInstructionNotFound),
+		errors.Is(err, standing.ErrPocketNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, standing.ErrInvalidInstruction),
+		errors.Is(err, standing.ErrInvalidPocket):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, standing.ErrInstructionExists),
+		errors.Is(err, standing.ErrPocketExists),
+		errors.Is(err, standing.ErrPocketBalance),
+		errors.Is(err, standing.ErrRunInProgress):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Standing instruction request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
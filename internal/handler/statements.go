@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/statement"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// StatementsHandler lets corporates have daily statements of their wallets
+// pushed to their ERP, follow each delivery and regenerate past days.
+type StatementsHandler struct {
+	service *statement.Service
+	logger  logger.Logger
+}
+
+func NewStatementsHandler(service *statement.Service, log logger.Logger) *StatementsHandler {
+	return &StatementsHandler{service: service, logger: log}
+}
+
+func (h *StatementsHandler) merchant(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	return requireMerchant(w, r, "Wallet statements are available to merchant accounts")
+}
+
+// ListSchedules returns the caller's statement schedules.
+func (h *StatementsHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.listSchedules(w, r, &userID)
+}
+
+// CreateSchedule sends one of the caller's wallets' statements daily.
+func (h *StatementsHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	var req statement.ScheduleRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	sch, err := h.service.CreateSchedule(r.Context(), userID, req, userID)
+	if err != nil {
+		h.respondStatementError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, sch)
+}
+
+// DeleteSchedule stops one of the caller's statement schedules.
+func (h *StatementsHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.deleteSchedule(w, r, &userID)
+}
+
+// Regenerate builds one of the caller's statements for a past day again and
+// delivers it.
+func (h *StatementsHandler) Regenerate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.regenerate(w, r, &userID, userID)
+}
+
+// ListDeliveries returns the caller's statements, newest first.
+func (h *StatementsHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.listDeliveries(w, r, &userID)
+}
+
+// GetDelivery returns one of the caller's statements and how its delivery
+// went.
+func (h *StatementsHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.getDelivery(w, r, &userID)
+}
+
+// File downloads one of the caller's statement files.
+func (h *StatementsHandler) File(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.file(w, r, &userID)
+}
+
+// AdminListSchedules returns every statement schedule, or one owner's with
+// ?owner_id= (admin).
+func (h *StatementsHandler) AdminListSchedules(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	owner, ok := ownerParam(w, r)
+	if !ok {
+		return
+	}
+	h.listSchedules(w, r, owner)
+}
+
+// AdminDeleteSchedule stops any statement schedule (admin).
+func (h *StatementsHandler) AdminDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.deleteSchedule(w, r, nil)
+}
+
+// AdminRegenerate regenerates any schedule's statement for a past day
+// (admin).
+func (h *StatementsHandler) AdminRegenerate(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	h.regenerate(w, r, nil, adminID)
+}
+
+// AdminListDeliveries returns every statement delivery, or one owner's with
+// ?owner_id= (admin).
+func (h *StatementsHandler) AdminListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	owner, ok := ownerParam(w, r)
+	if !ok {
+		return
+	}
+	h.listDeliveries(w, r, owner)
+}
+
+// AdminGetDelivery returns any statement delivery (admin).
+func (h *StatementsHandler) AdminGetDelivery(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.getDelivery(w, r, nil)
+}
+
+// AdminFile downloads any statement file (admin).
+func (h *StatementsHandler) AdminFile(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.file(w, r, nil)
+}
+
+func (h *StatementsHandler) listSchedules(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	schedules, err := h.service.ListSchedules(r.Context(), owner)
+	if err != nil {
+		h.respondStatementError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"schedules": schedules})
+}
+
+func (h *StatementsHandler) deleteSchedule(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+	if err := h.service.DeleteSchedule(r.Context(), owner, id); err != nil {
+		h.respondStatementError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *StatementsHandler) regenerate(w http.ResponseWriter, r *http.Request, owner *uuid.UUID, requestedBy uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid schedule ID")
+		return
+	}
+	var req struct {
+		Date string `json:"date"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	day, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "date must be a day such as 2026-03-01")
+		return
+	}
+	d, err := h.service.Regenerate(r.Context(), owner, id, day, requestedBy)
+	if err != nil {
+		h.respondStatementError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, d)
+}
+
+func (h *StatementsHandler) listDeliveries(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	limit, offset := parsePagination(r)
+	filter := statement.DeliveryFilter{
+		OwnerID: owner,
+		Status:  domain.StatementDeliveryStatus(r.URL.Query().Get("status")),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	if v := r.URL.Query().Get("schedule_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid schedule ID")
+			return
+		}
+		filter.ScheduleID = &id
+	}
+	deliveries, total, err := h.service.ListDeliveries(r.Context(), filter)
+	if err != nil {
+		h.respondStatementError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  deliveries,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *StatementsHandler) getDelivery(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+	d, err := h.service.GetDelivery(r.Context(), owner, id)
+	if err != nil {
+		h.respondStatementError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, d)
+}
+
+func (h *StatementsHandler) file(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+	d, err := h.service.File(r.Context(), owner, id)
+	if err != nil {
+		h.respondStatementError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", statement.ContentType(d.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", d.FileName))
+	w.Header().Set("Content-Length", strconv.Itoa(len(d.Content)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Content-SHA256", d.SHA256)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(d.Content)
+}
+
+// ownerParam reads the optional ?owner_id= of admin listings.
+func ownerParam(w http.ResponseWriter, r *http.Request) (*uuid.UUID, bool) {
+	v := r.URL.Query().Get("owner_id")
+	if v == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid owner ID")
+		return nil, false
+	}
+	return &id, true
+}
+
+func (h *StatementsHandler) respondStatementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, statement.ErrInvalidSchedule), errors.Is(err, statement.ErrInvalidPeriod):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, statement.ErrScheduleNotFound), errors.Is(err, statement.ErrDeliveryNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, statement.ErrScheduleExists), errors.Is(err, statement.ErrNotReady):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Statement request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process statement request")
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SupportHandler serves the call-centre transaction lookup. It needs no
+// session, so besides the per-IP rate limit on its route it locks a
+// reference after repeated lookups that did not match it, which stops the
+// phone digits being guessed from many addresses.
+type SupportHandler struct {
+	service     *payment.Service
+	redis       *redis.Client
+	validator   *validator.Validator
+	logger      logger.Logger
+	maxFailures int
+	lockout     time.Duration
+}
+
+func NewSupportHandler(service *payment.Service, redisClient *redis.Client, val *validator.Validator, log logger.Logger, maxFailures int, lockout time.Duration) *SupportHandler {
+	return &SupportHandler{
+		service:     service,
+		redis:       redisClient,
+		validator:   val,
+		logger:      log,
+		maxFailures: maxFailures,
+		lockout:     lockout,
+	}
+}
+
+func supportFailuresKey(ref string) string {
+	return "support-lookup:failures:" + ref
+}
+
+// Lookup returns the masked status of the transaction identified by
+// reference, sender phone last digits and amount.
+func (h *SupportHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	var req payment.SupportLookupRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := h.validator.ValidateStructured(&req); errs != nil {
+		respondValidationErrors(w, errs)
+		return
+	}
+	if !req.Amount.IsPositive() {
+		respondError(w, http.StatusBadRequest, "amount must be greater than zero")
+		return
+	}
+
+	ctx := r.Context()
+	ip := middleware.ClientIP(r)
+	key := supportFailuresKey(payment.SupportLookupKey(req.Reference))
+	if failures, _ := h.redis.Get(ctx, key).Int(); failures >= h.maxFailures {
+		h.service.AuditSupportLookup(ctx, req, ip, payment.SupportLookupLocked, nil)
+		if ttl := h.redis.TTL(ctx, key).Val(); ttl > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(ttl.Seconds())))
+		}
+		respondError(w, http.StatusTooManyRequests, "Too many failed lookups for this reference")
+		return
+	}
+
+	result, err := h.service.SupportLookup(ctx, req, ip)
+	if errors.Is(err, payment.ErrSupportLookupMismatch) {
+		if n, _ := h.redis.Incr(ctx, key).Result(); n == 1 {
+			h.redis.Expire(ctx, key, h.lockout)
+		}
+		respondError(w, http.StatusNotFound, "No transaction matches these details")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Support lookup failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Lookup failed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, http.StatusOK, result)
+}
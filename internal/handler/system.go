@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"kyd/internal/domain"
-	"kyd/internal/middleware"
 	"kyd/internal/repository/postgres"
 
 	"github.com/google/uuid"
@@ -179,8 +178,7 @@ func (h *SystemHandler) GetSystemStatus(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *SystemHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -242,9 +240,23 @@ func (h *SystemHandler) GetNotifications(w http.ResponseWriter, r *http.Request)
 	h.respondJSON(w, http.StatusOK, response)
 }
 
+// GetQueryStats returns latency and row counts of the instrumented
+// repository queries, most expensive first, and the connection pool state.
+func (h *SystemHandler) GetQueryStats(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"queries":           postgres.QueryStatsSnapshot(),
+		"slow_threshold_ms": postgres.SlowQueryThreshold().Milliseconds(),
+		"pool":              postgres.PoolStatsOf("payment", h.db),
+	})
+}
+
 func (h *SystemHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -266,8 +278,7 @@ func (h *SystemHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Requ
 }
 
 func (h *SystemHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -281,8 +292,7 @@ func (h *SystemHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.
 }
 
 func (h *SystemHandler) ArchiveNotification(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
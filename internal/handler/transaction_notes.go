@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// GetNote returns the caller's private note on one of their transactions.
+func (h *PaymentHandler) GetNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	txID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	note, err := h.service.GetUserNote(r.Context(), txID, userID)
+	if err != nil {
+		h.respondNoteError(w, err)
+		return
+	}
+	if note == nil {
+		h.respondError(w, http.StatusNotFound, "No note on this transaction")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, note)
+}
+
+// SetNote replaces the caller's private note and tags on one of their
+// transactions. An empty note with no tags removes it.
+func (h *PaymentHandler) SetNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	txID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	var req struct {
+		Note string   `json:"note"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	note, err := h.service.SetUserNote(r.Context(), txID, userID, req.Note, req.Tags)
+	if err != nil {
+		h.respondNoteError(w, err)
+		return
+	}
+	if note == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, note)
+}
+
+// ListInternalNotes returns a transaction's support notes (admin).
+func (h *PaymentHandler) ListInternalNotes(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	txID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	notes, err := h.service.ListInternalNotes(r.Context(), txID)
+	if err != nil {
+		h.logger.Error("Failed to list internal notes", map[string]interface{}{"transaction_id": txID, "error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to list notes")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"notes": notes})
+}
+
+// AddInternalNote appends a support note to a transaction (admin). The
+// customer never sees it.
+func (h *PaymentHandler) AddInternalNote(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		h.respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	txID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid transaction ID")
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	note, err := h.service.AddInternalNote(r.Context(), txID, adminID, req.Note)
+	if err != nil {
+		h.respondNoteError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, note)
+}
+
+func (h *PaymentHandler) respondNoteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, payment.ErrInvalidNote):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, payment.ErrNotTransactionParty), errors.Is(err, pkgerrors.ErrTransactionNotFound):
+		h.respondError(w, http.StatusNotFound, "Transaction not found")
+	case errors.Is(err, payment.ErrNotesDisabled):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	default:
+		h.logger.Error("Transaction note request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to save note")
+	}
+}
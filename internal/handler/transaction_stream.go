@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"kyd/internal/middleware"
+	"kyd/internal/txstream"
+	"kyd/pkg/logger"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamPingInterval keeps idle sockets open through proxies and finds
+	// clients that went away without closing.
+	streamPingInterval = 30 * time.Second
+	// streamPongWait is how long a client may take to answer a ping.
+	streamPongWait   = 2 * streamPingInterval
+	streamWriteWait  = 10 * time.Second
+	streamMaxMessage = 512
+)
+
+// TransactionStreamHandler streams status changes of the caller's
+// transactions over a WebSocket.
+type TransactionStreamHandler struct {
+	hub    *txstream.Hub
+	logger logger.Logger
+}
+
+func NewTransactionStreamHandler(hub *txstream.Hub, log logger.Logger) *TransactionStreamHandler {
+	return &TransactionStreamHandler{hub: hub, logger: log}
+}
+
+// Stream upgrades the request to a WebSocket and sends the caller an update
+// each time one of their transactions, sent or received, changes status.
+// Clients only listen; what they send is ignored. The socket is closed with
+// 1013 (try again later) when the client falls behind or the service shuts
+// down; the client should reconnect and fetch its transactions again.
+func (h *TransactionStreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered the client
+		h.logger.Warn("Transaction stream upgrade failed", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe(userID)
+	defer h.hub.Unsubscribe(sub)
+
+	// Reading handles pings, pongs and the client's close
+	gone := make(chan struct{})
+	conn.SetReadLimit(streamMaxMessage)
+	_ = conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-gone:
+			return
+		case <-r.Context().Done():
+			return
+		case u, ok := <-sub.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "reconnect to resume updates"))
+				return
+			}
+			if err := conn.WriteJSON(u); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
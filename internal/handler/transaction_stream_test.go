@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/middleware"
+	"kyd/internal/txstream"
+	"kyd/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// idleBus never announces anything; the test delivers to the hub itself.
+type idleBus struct{}
+
+func (idleBus) Publish(ctx context.Context, u *txstream.Update) error { return nil }
+
+func (idleBus) Subscribe(ctx context.Context, fn func(*txstream.Update)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTransactionStream(t *testing.T) {
+	hub := txstream.NewHub(idleBus{}, logger.NewNop())
+	h := NewTransactionStreamHandler(hub, logger.NewNop())
+	// Behind the logging middleware, as on the router, which must let the
+	// upgrade take over the connection
+	srv := httptest.NewServer(middleware.NewLoggingMiddleware(logger.NewNop()).Log(
+		middleware.NewAuthMiddleware(testJWTSecret, nil).Authenticate(http.HandlerFunc(h.Stream))))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	me := uuid.New()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": me.String()}).SignedString([]byte(testJWTSecret))
+	require.NoError(t, err)
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer " + token}})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.Connections() == 1 }, time.Second, time.Millisecond)
+
+	sent := &txstream.Update{ID: uuid.New(), Type: txstream.UpdateCompleted, Transaction: txstream.TransactionSummary{ID: uuid.New(), SenderID: uuid.New(), ReceiverID: me}}
+	hub.Deliver(sent)
+	var got txstream.Update
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&got))
+	assert.Equal(t, sent.ID, got.ID)
+	assert.Equal(t, txstream.UpdateCompleted, got.Type)
+	assert.Equal(t, sent.Transaction.ID, got.Transaction.ID)
+
+	// Shutting down asks the client to come back
+	hub.Stop()
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), err)
+}
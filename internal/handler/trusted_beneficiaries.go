@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/payment"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ListTrustedBeneficiaries returns the caller's trusted beneficiaries,
+// including those still in their cooling-off period.
+func (h *PaymentHandler) ListTrustedBeneficiaries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	items, err := h.service.ListTrustedBeneficiaries(r.Context(), userID)
+	if err != nil {
+		h.respondBeneficiaryError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"beneficiaries": items})
+}
+
+// AddTrustedBeneficiary trusts a wallet. The caller re-authenticates with
+// step_up, and the beneficiary only counts after the cooling-off period.
+func (h *PaymentHandler) AddTrustedBeneficiary(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct {
+		WalletNumber string          `json:"wallet_number"`
+		Nickname     string          `json:"nickname"`
+		StepUp       *payment.StepUp `json:"step_up"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.WalletNumber == "" {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	b, err := h.service.AddTrustedBeneficiary(r.Context(), userID, req.WalletNumber, req.Nickname, req.StepUp)
+	if err != nil {
+		h.respondBeneficiaryError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, b)
+}
+
+// RemoveTrustedBeneficiary stops trusting a beneficiary at once. The caller
+// re-authenticates with step_up in the body.
+func (h *PaymentHandler) RemoveTrustedBeneficiary(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid beneficiary ID")
+		return
+	}
+	var req struct {
+		StepUp *payment.StepUp `json:"step_up"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.service.RemoveTrustedBeneficiary(r.Context(), userID, id, req.StepUp); err != nil {
+		h.respondBeneficiaryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PaymentHandler) respondBeneficiaryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pkgerrors.ErrStepUpLocked):
+		h.respondError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, payment.ErrStepUpRequired), errors.Is(err, payment.ErrStepUpFailed):
+		h.respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, payment.ErrInvalidBeneficiary):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, payment.ErrBeneficiaryNotFound):
+		h.respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, pkgerrors.ErrWalletNotFound):
+		h.respondError(w, http.StatusNotFound, "Wallet not found")
+	case errors.Is(err, payment.ErrBeneficiariesDisabled):
+		h.respondError(w, http.StatusNotImplemented, err.Error())
+	default:
+		h.logger.Error("Trusted beneficiary request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process trusted beneficiaries")
+	}
+}
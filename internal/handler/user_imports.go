@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/userimport"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// UserImportsHandler lets staff onboard people in bulk from a CSV and follow
+// each import's progress.
+type UserImportsHandler struct {
+	service *userimport.Service
+	logger  logger.Logger
+}
+
+func NewUserImportsHandler(service *userimport.Service, log logger.Logger) *UserImportsHandler {
+	return &UserImportsHandler{service: service, logger: log}
+}
+
+// userImportResponse adds an import's progress to it.
+type userImportResponse struct {
+	*domain.UserImport
+	ValidRows int     `json:"valid_rows"`
+	Progress  float64 `json:"progress"`
+}
+
+func newUserImportResponse(imp *domain.UserImport) userImportResponse {
+	return userImportResponse{UserImport: imp, ValidRows: imp.ValidRows(), Progress: imp.Progress()}
+}
+
+// Upload takes a multipart form with the CSV as "file" and, optionally, the
+// "organization" the people belong to. Row validation errors come back at
+// once; the valid rows are imported in the background.
+func (h *UserImportsHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		respondError(w, http.StatusBadRequest, "File too large or invalid form")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Missing file")
+		return
+	}
+	defer file.Close()
+
+	var createdBy *uuid.UUID
+	if id, ok := middleware.UserIDFromContext(r.Context()); ok {
+		createdBy = &id
+	}
+	imp, rows, err := h.service.Upload(r.Context(), &userimport.UploadRequest{
+		FileName:     header.Filename,
+		Organization: r.FormValue("organization"),
+		Content:      file,
+		CreatedBy:    createdBy,
+	})
+	if errors.Is(err, userimport.ErrInvalidFile) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to import users", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to import users")
+		return
+	}
+
+	type rowErrors struct {
+		Line   int                     `json:"line"`
+		Errors domain.UserImportErrors `json:"errors"`
+	}
+	invalid := make([]rowErrors, 0, imp.InvalidRows)
+	for _, row := range rows {
+		if row.Status == domain.UserImportRowInvalid {
+			invalid = append(invalid, rowErrors{Line: row.Line, Errors: row.Errors})
+		}
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"import":  newUserImportResponse(imp),
+		"invalid": invalid,
+	})
+}
+
+// List returns imports, newest first.
+func (h *UserImportsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	limit, offset := parsePagination(r)
+	imports, total, err := h.service.List(r.Context(), limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list user imports", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to list user imports")
+		return
+	}
+	items := make([]userImportResponse, len(imports))
+	for i := range imports {
+		items[i] = newUserImportResponse(&imports[i])
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Get returns an import and its progress.
+func (h *UserImportsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+	imp, err := h.service.Get(r.Context(), id)
+	if errors.Is(err, userimport.ErrNotFound) {
+		respondError(w, http.StatusNotFound, "User import not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get user import", map[string]interface{}{"error": err.Error(), "import_id": id})
+		respondError(w, http.StatusInternalServerError, "Failed to get user import")
+		return
+	}
+	respondJSON(w, http.StatusOK, newUserImportResponse(imp))
+}
+
+// Rows returns an import's per-row results, optionally filtered by status.
+// Personal data is masked unless the caller may see it.
+func (h *UserImportsHandler) Rows(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid import ID")
+		return
+	}
+	status := domain.UserImportRowStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", domain.UserImportRowInvalid, domain.UserImportRowPending, domain.UserImportRowInvited, domain.UserImportRowFailed:
+	default:
+		respondError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	limit, offset := parsePagination(r)
+	rows, total, err := h.service.Rows(r.Context(), id, status, limit, offset)
+	if errors.Is(err, userimport.ErrNotFound) {
+		respondError(w, http.StatusNotFound, "User import not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list user import rows", map[string]interface{}{"error": err.Error(), "import_id": id})
+		respondError(w, http.StatusInternalServerError, "Failed to list user import rows")
+		return
+	}
+	if !seesUnmaskedData(r) {
+		rows = maskUserImportRows(rows)
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  rows,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -13,12 +13,12 @@ import (
 	"kyd/internal/payment"
 	"kyd/internal/security"
 	"kyd/internal/wallet"
+	"kyd/pkg/clock"
 	"kyd/pkg/domain"
 	"kyd/pkg/validator"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UsersHandler struct {
@@ -65,8 +65,7 @@ type listUsersResponse struct {
 }
 
 func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -102,6 +101,9 @@ func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusInternalServerError, "Failed to list users")
 		return
 	}
+	if !seesUnmaskedData(r) {
+		users = maskUsers(users)
+	}
 	respondJSON(w, http.StatusOK, listUsersResponse{
 		Users:  users,
 		Items:  users,
@@ -112,8 +114,7 @@ func (h *UsersHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UsersHandler) Get(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -128,6 +129,9 @@ func (h *UsersHandler) Get(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusNotFound, "User not found")
 		return
 	}
+	if !seesUnmaskedData(r) {
+		user = maskUser(user)
+	}
 	respondJSON(w, http.StatusOK, user)
 }
 
@@ -152,8 +156,7 @@ type updateUserRequest struct {
 }
 
 func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -183,6 +186,7 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// Apply updates
 	now := time.Now()
 	user.UpdatedAt = now
+	previousPhone := user.Phone
 	if req.Email != nil {
 		user.Email = *req.Email
 	}
@@ -233,6 +237,7 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 	// Sanitize user fields
 	auth.SanitizeUserInput(user)
+	phoneChanged := auth.ResetPhoneVerification(user, previousPhone)
 	// Handle password update
 	if req.Password != nil && *req.Password != "" {
 		if err := h.service.ChangePassword(r.Context(), user, *req.Password); err != nil {
@@ -249,6 +254,9 @@ func (h *UsersHandler) Update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if phoneChanged {
+		h.sendPhoneVerification(r, user.ID)
+	}
 	respondJSON(w, http.StatusOK, user)
 }
 
@@ -261,6 +269,8 @@ type updateMeRequest struct {
 	City        *string `json:"city" validate:"omitempty,max=100"`
 	PostalCode  *string `json:"postal_code" validate:"omitempty,max=20"`
 	TaxID       *string `json:"tax_id" validate:"omitempty,max=50"`
+	LoginAlerts *bool   `json:"login_alerts"`
+	TimeZone    *string `json:"time_zone"`
 }
 
 func (h *UsersHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
@@ -286,6 +296,11 @@ func (h *UsersHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 		respondValidationErrors(w, errs)
 		return
 	}
+	if req.TimeZone != nil && !clock.ValidZone(*req.TimeZone) {
+		respondError(w, http.StatusBadRequest, "time_zone must be an IANA zone name such as Africa/Blantyre")
+		return
+	}
+	previousPhone := user.Phone
 	if req.Phone != nil {
 		user.Phone = *req.Phone
 	}
@@ -310,15 +325,36 @@ func (h *UsersHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 	if req.TaxID != nil {
 		user.TaxID = *req.TaxID
 	}
+	if req.LoginAlerts != nil {
+		user.LoginAlertsDisabled = !*req.LoginAlerts
+	}
+	if req.TimeZone != nil {
+		user.TimeZone = *req.TimeZone
+	}
 	auth.SanitizeUserInput(user)
+	phoneChanged := auth.ResetPhoneVerification(user, previousPhone)
 	user.UpdatedAt = time.Now()
 	if err := h.service.UpdateUser(r.Context(), user); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to update profile")
 		return
 	}
+	if phoneChanged {
+		h.sendPhoneVerification(r, user.ID)
+	}
 	respondJSON(w, http.StatusOK, user)
 }
 
+// sendPhoneVerification sends a code to a newly changed number. Failures are
+// logged only; the user can request another code.
+func (h *UsersHandler) sendPhoneVerification(r *http.Request, userID uuid.UUID) {
+	if err := h.service.SendPhoneVerification(r.Context(), userID); err != nil {
+		h.logger.Warn("Failed to send phone verification code", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
+}
+
 type changePasswordRequest struct {
 	Current string `json:"current_password" validate:"required"`
 	New     string `json:"new_password" validate:"required,min=8"`
@@ -347,7 +383,7 @@ func (h *UsersHandler) ChangeMyPassword(w http.ResponseWriter, r *http.Request)
 		respondValidationErrors(w, errs)
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Current)); err != nil {
+	if !h.service.VerifyPassword(user.PasswordHash, req.Current) {
 		respondError(w, http.StatusUnauthorized, "Current password is incorrect")
 		return
 	}
@@ -359,8 +395,7 @@ func (h *UsersHandler) ChangeMyPassword(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *UsersHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -419,8 +454,7 @@ func (h *UsersHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UsersHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -479,8 +513,7 @@ func (h *UsersHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -565,8 +598,7 @@ func (h *UsersHandler) writeAdminAudit(ctx context.Context, r *http.Request, act
 }
 
 func (h *UsersHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -611,8 +643,7 @@ func (h *UsersHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UsersHandler) GetOverview(w http.ResponseWriter, r *http.Request) {
-	ut, ok := middleware.UserTypeFromContext(r.Context())
-	if !ok || ut != string(domain.UserTypeAdmin) {
+	if !isAdminRequest(r) {
 		respondError(w, http.StatusForbidden, "Forbidden")
 		return
 	}
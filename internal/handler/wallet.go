@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 
+	"kyd/internal/domain"
 	"kyd/internal/middleware"
 	"kyd/internal/wallet"
 	"kyd/pkg/errors"
@@ -85,54 +86,6 @@ func (h *WalletHandler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusCreated, wallet)
 }
 
-// Deposit handles adding funds to a wallet
-func (h *WalletHandler) Deposit(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	walletID, err := uuid.Parse(vars["id"])
-	if err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid wallet ID")
-		return
-	}
-
-	var req wallet.DepositRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	req.WalletID = walletID
-
-	// Authorization: wallet owner or admin can perform top-up.
-	userID, hasUser := middleware.UserIDFromContext(r.Context())
-	userType, _ := middleware.UserTypeFromContext(r.Context())
-	targetWallet, findErr := h.service.GetWallet(r.Context(), walletID)
-	if findErr != nil {
-		h.respondError(w, http.StatusNotFound, "Wallet not found")
-		return
-	}
-	if !hasUser || (userType != "admin" && targetWallet.UserID != userID) {
-		h.respondError(w, http.StatusForbidden, "You are not allowed to top up this wallet")
-		return
-	}
-
-	if errs := h.validator.ValidateStructured(&req); errs != nil {
-		h.respondValidationErrors(w, errs)
-		return
-	}
-
-	updatedWallet, err := h.service.Deposit(r.Context(), &req)
-	if err != nil {
-		h.logger.Error("Deposit failed", map[string]interface{}{
-			"error":     err.Error(),
-			"wallet_id": walletID,
-		})
-		h.respondError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	h.respondJSON(w, http.StatusOK, updatedWallet)
-}
-
 // GetWallet returns a wallet by ID.
 func (h *WalletHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -164,6 +117,9 @@ func (h *WalletHandler) GetUserWallets(w http.ResponseWriter, r *http.Request) {
 		h.respondError(w, http.StatusInternalServerError, "Failed to fetch wallets")
 		return
 	}
+	if !seesUnmaskedData(r) {
+		wallets = maskBalances(wallets)
+	}
 
 	h.respondJSON(w, http.StatusOK, map[string]interface{}{
 		"wallets": wallets,
@@ -173,8 +129,7 @@ func (h *WalletHandler) GetUserWallets(w http.ResponseWriter, r *http.Request) {
 
 // GetAllWallets lists all wallets (Admin only).
 func (h *WalletHandler) GetAllWallets(w http.ResponseWriter, r *http.Request) {
-	ut, _ := middleware.UserTypeFromContext(r.Context())
-	if ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -223,6 +178,10 @@ func (h *WalletHandler) LookupWallet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	info, err := h.service.LookupWallet(r.Context(), address)
+	if err == wallet.ErrInvalidAddress {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error("Wallet lookup failed", map[string]interface{}{
 			"address": address,
@@ -290,9 +249,72 @@ func (h *WalletHandler) GetTransactionHistory(w http.ResponseWriter, r *http.Req
 	})
 }
 
+// GetActivity returns a wallet's history from the activity feed. Clients
+// that send a cursor (an empty one starts from the newest entry) get keyset
+// pages without a total; others page by offset.
+func (h *WalletHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	walletID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+
+	limit, offset := parsePagination(r)
+
+	if r.URL.Query().Has("cursor") {
+		var after *domain.TransactionCursor
+		if v := r.URL.Query().Get("cursor"); v != "" {
+			c, err := domain.ParseTransactionCursor(v)
+			if err != nil {
+				h.respondError(w, http.StatusBadRequest, "Invalid cursor")
+				return
+			}
+			after = c
+		}
+		items, next, err := h.service.GetActivity(r.Context(), walletID, userID, after, limit)
+		if err != nil {
+			h.respondError(w, http.StatusInternalServerError, "Failed to fetch transaction history")
+			return
+		}
+		var nextCursor string
+		if next != nil {
+			nextCursor = next.Encode()
+		}
+		h.respondJSON(w, http.StatusOK, map[string]interface{}{
+			"wallet_id":    walletID,
+			"transactions": items,
+			"limit":        limit,
+			"count":        len(items),
+			"next_cursor":  nextCursor,
+		})
+		return
+	}
+
+	items, total, err := h.service.GetActivityPage(r.Context(), walletID, userID, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch transaction history")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"wallet_id":    walletID,
+		"transactions": items,
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+		"count":        len(items),
+	})
+}
+
 func (h *WalletHandler) GetTransactionHistoryAdmin(w http.ResponseWriter, r *http.Request) {
-	ut, _ := middleware.UserTypeFromContext(r.Context())
-	if ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -323,8 +345,7 @@ func (h *WalletHandler) GetTransactionHistoryAdmin(w http.ResponseWriter, r *htt
 }
 
 func (h *WalletHandler) FixWalletAddresses(w http.ResponseWriter, r *http.Request) {
-	ut, _ := middleware.UserTypeFromContext(r.Context())
-	if ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
@@ -342,8 +363,7 @@ func (h *WalletHandler) FixWalletAddresses(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *WalletHandler) GetBlockchainWallets(w http.ResponseWriter, r *http.Request) {
-	ut, _ := middleware.UserTypeFromContext(r.Context())
-	if ut != "admin" {
+	if !isAdminRequest(r) {
 		h.respondError(w, http.StatusForbidden, "admin access required")
 		return
 	}
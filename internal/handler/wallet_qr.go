@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"net/http"
+
+	"kyd/internal/middleware"
+	"kyd/internal/wallet"
+	"kyd/pkg/emvqr"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// qrImageSize is the width and height of QR code images, in pixels.
+const qrImageSize = 256
+
+type walletQRResponse struct {
+	*wallet.WalletQRCode
+	// QRCode is the payload as a PNG data URI.
+	QRCode string `json:"qr_code"`
+}
+
+// GetQRCode returns the QR code for paying one of the caller's wallets,
+// asking for ?amount= and passing ?reference= on when given.
+func (h *WalletHandler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	walletID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid wallet ID")
+		return
+	}
+	var amount *decimal.Decimal
+	if v := r.URL.Query().Get("amount"); v != "" {
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "Invalid amount")
+			return
+		}
+		amount = &d
+	}
+	code, err := h.service.QRCode(r.Context(), userID, walletID, amount, r.URL.Query().Get("reference"))
+	if err != nil {
+		h.respondQRError(w, err)
+		return
+	}
+	image, err := qrImage(code.Payload)
+	if err != nil {
+		h.logger.Error("Failed to render wallet QR code", map[string]interface{}{"error": err.Error(), "wallet_id": walletID})
+		h.respondError(w, http.StatusInternalServerError, "Failed to render QR code")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, walletQRResponse{WalletQRCode: code, QRCode: image})
+}
+
+// DecodeQRCode checks a scanned wallet QR code and returns the payee and
+// what the code asks for, before the app initiates the payment.
+func (h *WalletHandler) DecodeQRCode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Payload string `json:"payload"`
+	}
+	if err := decodeStrict(w, r, &req); err != nil || req.Payload == "" {
+		h.respondError(w, http.StatusBadRequest, "payload is required")
+		return
+	}
+	scanned, err := h.service.DecodeQRCode(r.Context(), req.Payload)
+	if err != nil {
+		h.respondQRError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, scanned)
+}
+
+func (h *WalletHandler) respondQRError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pkgerrors.ErrWalletNotFound):
+		h.respondError(w, http.StatusNotFound, "Wallet not found")
+	case errors.Is(err, pkgerrors.ErrWalletNotActive):
+		h.respondError(w, http.StatusConflict, "Wallet is not active")
+	case errors.Is(err, wallet.ErrInvalidQRRequest), errors.Is(err, wallet.ErrInvalidAddress),
+		errors.Is(err, emvqr.ErrInvalid), errors.Is(err, emvqr.ErrChecksum):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, emvqr.ErrUnsupported):
+		h.respondError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		h.logger.Error("Wallet QR code request failed", map[string]interface{}{"error": err.Error()})
+		h.respondError(w, http.StatusInternalServerError, "Failed to process request")
+	}
+}
+
+// qrImage renders payload as a PNG data URI.
+func qrImage(payload string) (string, error) {
+	code, err := qr.Encode(payload, qr.M, qr.Auto)
+	if err != nil {
+		return "", err
+	}
+	code, err = barcode.Scale(code, qrImageSize, qrImageSize)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
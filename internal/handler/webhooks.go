@@ -0,0 +1,553 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/middleware"
+	"kyd/internal/webhook"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// WebhooksHandler lets merchants manage their webhook endpoints and admins
+// manage everyone's, including platform endpoints that receive every event.
+type WebhooksHandler struct {
+	service *webhook.Service
+	logger  logger.Logger
+}
+
+func NewWebhooksHandler(service *webhook.Service, log logger.Logger) *WebhooksHandler {
+	return &WebhooksHandler{service: service, logger: log}
+}
+
+// endpointWithSecret is returned on create and rotation, the only times
+// the signing secret is shown.
+type endpointWithSecret struct {
+	*domain.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+// merchant returns the calling merchant's ID, or responds and returns false.
+func (h *WebhooksHandler) merchant(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	return requireMerchant(w, r, "Webhooks are available to merchant accounts")
+}
+
+// requireMerchant returns the caller's ID if they are a merchant, or
+// responds with forbidden and returns false.
+func requireMerchant(w http.ResponseWriter, r *http.Request, forbidden string) (uuid.UUID, bool) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, false
+	}
+	ut, _ := middleware.UserTypeFromContext(r.Context())
+	if ut != string(domain.UserTypeMerchant) {
+		respondError(w, http.StatusForbidden, forbidden)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// List returns the caller's endpoints.
+func (h *WebhooksHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.list(w, r, &userID)
+}
+
+// Create registers an endpoint for the caller.
+func (h *WebhooksHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.create(w, r, &userID, userID)
+}
+
+// Get returns one of the caller's endpoints.
+func (h *WebhooksHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.service.GetEndpoint(r.Context(), &userID, id)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, e)
+}
+
+// Update changes one of the caller's endpoints.
+func (h *WebhooksHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.update(w, r, &userID)
+}
+
+// Delete removes one of the caller's endpoints.
+func (h *WebhooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.delete(w, r, &userID)
+}
+
+// RotateSecret issues a new signing secret for one of the caller's endpoints.
+func (h *WebhooksHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.rotateSecret(w, r, &userID)
+}
+
+// Deliveries returns the delivery log of one of the caller's endpoints.
+func (h *WebhooksHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.deliveries(w, r, &userID)
+}
+
+// Delivery returns one delivery of one of the caller's endpoints.
+func (h *WebhooksHandler) Delivery(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.delivery(w, r, &userID)
+}
+
+// ReplayDelivery sends the event of one of the caller's deliveries again.
+func (h *WebhooksHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.replayDelivery(w, r, &userID)
+}
+
+// Replay sends again the events one of the caller's endpoints was sent in
+// a time range.
+func (h *WebhooksHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.replay(w, r, &userID)
+}
+
+// Signature returns the signature deliveries of a sample payload to one of
+// the caller's endpoints carry, and checks one the caller computed.
+func (h *WebhooksHandler) Signature(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.signature(w, r, &userID)
+}
+
+// SendTest sends one of the caller's endpoints a test event.
+func (h *WebhooksHandler) SendTest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.merchant(w, r)
+	if !ok {
+		return
+	}
+	h.sendTest(w, r, &userID)
+}
+
+// AdminList returns every endpoint (admin).
+func (h *WebhooksHandler) AdminList(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	var owner *uuid.UUID
+	if v := r.URL.Query().Get("owner_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid owner ID")
+			return
+		}
+		owner = &id
+	}
+	h.list(w, r, owner)
+}
+
+type adminCreateWebhookRequest struct {
+	webhook.EndpointRequest
+	OwnerID *uuid.UUID `json:"owner_id"`
+}
+
+// AdminCreate registers an endpoint for owner_id, or a platform endpoint
+// when it is omitted (admin).
+func (h *WebhooksHandler) AdminCreate(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	adminID, _ := middleware.UserIDFromContext(r.Context())
+	var req adminCreateWebhookRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	e, secret, err := h.service.CreateEndpoint(r.Context(), req.OwnerID, adminID, req.EndpointRequest)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, endpointWithSecret{WebhookEndpoint: e, Secret: secret})
+}
+
+// AdminUpdate changes any endpoint (admin).
+func (h *WebhooksHandler) AdminUpdate(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.update(w, r, nil)
+}
+
+// AdminDelete removes any endpoint (admin).
+func (h *WebhooksHandler) AdminDelete(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.delete(w, r, nil)
+}
+
+// AdminRotateSecret issues a new signing secret for any endpoint (admin).
+func (h *WebhooksHandler) AdminRotateSecret(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.rotateSecret(w, r, nil)
+}
+
+// AdminDeliveries returns any endpoint's delivery log (admin).
+func (h *WebhooksHandler) AdminDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.deliveries(w, r, nil)
+}
+
+// AdminDelivery returns one delivery of any endpoint (admin).
+func (h *WebhooksHandler) AdminDelivery(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.delivery(w, r, nil)
+}
+
+// AdminReplayDelivery sends the event of any delivery again (admin).
+func (h *WebhooksHandler) AdminReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.replayDelivery(w, r, nil)
+}
+
+// AdminReplay sends again the events any endpoint was sent in a time range
+// (admin).
+func (h *WebhooksHandler) AdminReplay(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.replay(w, r, nil)
+}
+
+// AdminSignature is Signature for any endpoint (admin).
+func (h *WebhooksHandler) AdminSignature(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.signature(w, r, nil)
+}
+
+// AdminSendTest sends any endpoint a test event (admin).
+func (h *WebhooksHandler) AdminSendTest(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	h.sendTest(w, r, nil)
+}
+
+func (h *WebhooksHandler) list(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	endpoints, err := h.service.ListEndpoints(r.Context(), owner)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"endpoints": endpoints})
+}
+
+func (h *WebhooksHandler) create(w http.ResponseWriter, r *http.Request, owner *uuid.UUID, createdBy uuid.UUID) {
+	var req webhook.EndpointRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	e, secret, err := h.service.CreateEndpoint(r.Context(), owner, createdBy, req)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, endpointWithSecret{WebhookEndpoint: e, Secret: secret})
+}
+
+func (h *WebhooksHandler) update(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	var req webhook.EndpointRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	e, err := h.service.UpdateEndpoint(r.Context(), owner, id, req)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, e)
+}
+
+func (h *WebhooksHandler) delete(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteEndpoint(r.Context(), owner, id); err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type rotateSecretRequest struct {
+	// GracePeriodHours is how long the old secret keeps signing deliveries
+	// alongside the new one; omitted means webhook.DefaultRotationGrace.
+	GracePeriodHours *int `json:"grace_period_hours"`
+}
+
+func (h *WebhooksHandler) rotateSecret(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	var req rotateSecretRequest
+	// The body is optional.
+	if err := decodeStrict(w, r, &req); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	grace := webhook.DefaultRotationGrace
+	if req.GracePeriodHours != nil {
+		grace = time.Duration(*req.GracePeriodHours) * time.Hour
+	}
+	e, secret, err := h.service.RotateSecret(r.Context(), owner, id, grace)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, endpointWithSecret{WebhookEndpoint: e, Secret: secret})
+}
+
+// deliveries lists an endpoint's deliveries, filtered by the status,
+// event_type, from and to (RFC3339) query parameters.
+func (h *WebhooksHandler) deliveries(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit, offset := parsePagination(r)
+	filter := webhook.DeliveryFilter{
+		Status:    domain.WebhookDeliveryStatus(q.Get("status")),
+		EventType: domain.WebhookEventType(q.Get("event_type")),
+		Limit:     limit,
+		Offset:    offset,
+	}
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid from time, expected RFC3339")
+			return
+		}
+		filter.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid to time, expected RFC3339")
+			return
+		}
+		filter.To = &t
+	}
+	deliveries, err := h.service.ListDeliveries(r.Context(), owner, id, filter)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries, "limit": limit, "offset": offset})
+}
+
+func (h *WebhooksHandler) delivery(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, ok := deliveryID(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.service.GetDelivery(r.Context(), owner, id, deliveryID)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, d)
+}
+
+func (h *WebhooksHandler) replayDelivery(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, ok := deliveryID(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.service.Replay(r.Context(), owner, id, deliveryID)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, d)
+}
+
+func (h *WebhooksHandler) replay(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	var req webhook.ReplayRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	replays, err := h.service.ReplayRange(r.Context(), owner, id, req)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{"deliveries": replays, "count": len(replays)})
+}
+
+func (h *WebhooksHandler) signature(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	var req webhook.SignatureRequest
+	if err := decodeStrict(w, r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	check, err := h.service.Signature(r.Context(), owner, id, req)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, check)
+}
+
+type sendTestRequest struct {
+	EventType domain.WebhookEventType `json:"event_type"`
+}
+
+func (h *WebhooksHandler) sendTest(w http.ResponseWriter, r *http.Request, owner *uuid.UUID) {
+	id, ok := endpointID(w, r)
+	if !ok {
+		return
+	}
+	var req sendTestRequest
+	// The body is optional.
+	if err := decodeStrict(w, r, &req); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	res, err := h.service.SendTest(r.Context(), owner, id, req.EventType)
+	if err != nil {
+		h.respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, res)
+}
+
+func endpointID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func deliveryID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["deliveryID"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *WebhooksHandler) respondWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrEndpointNotFound), errors.Is(err, webhook.ErrDeliveryNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, webhook.ErrInvalidReplay), errors.Is(err, webhook.ErrInvalidSample),
+		errors.Is(err, webhook.ErrInvalidTestEvent):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, webhook.ErrInvalidRotation):
+		respondError(w, http.StatusBadRequest, "Invalid secret rotation: grace_period_hours must be between 0 and 168")
+	case errors.Is(err, webhook.ErrReplayTooLarge):
+		respondError(w, http.StatusUnprocessableEntity, "Too many deliveries to replay at once; narrow the time range or filter by status or event type")
+	case errors.Is(err, webhook.ErrInvalidEndpoint):
+		respondError(w, http.StatusBadRequest, "Invalid webhook endpoint: the URL must be https and events must be known event types")
+	case errors.Is(err, webhook.ErrTooManyEndpoints):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		h.logger.Error("Webhook request failed", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process webhook request")
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	"kyd/pkg/logger"
+)
+
+// WorkersHandler exposes the heartbeats of every process's background
+// workers to admins.
+type WorkersHandler struct {
+	watchdog *heartbeat.Watchdog
+	logger   logger.Logger
+}
+
+func NewWorkersHandler(watchdog *heartbeat.Watchdog, log logger.Logger) *WorkersHandler {
+	return &WorkersHandler{watchdog: watchdog, logger: log}
+}
+
+// List returns background workers with their last heartbeat and status,
+// optionally filtered by status (admin).
+func (h *WorkersHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		respondError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	beats, err := h.watchdog.List(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list worker heartbeats", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+	status := domain.WorkerStatus(r.URL.Query().Get("status"))
+	items := []domain.WorkerHeartbeat{}
+	for _, b := range beats {
+		if status == "" || b.Status == status {
+			items = append(items, b)
+		}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": len(items)})
+}
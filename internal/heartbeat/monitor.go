@@ -0,0 +1,316 @@
+// Package heartbeat watches that background workers keep running. Workers
+// beat once per pass of their loop; each process's Monitor records the
+// beats, restarts in-process workers that stop beating where that is safe,
+// and flushes them to the database. The Watchdog reads every process's
+// beats and alerts admins to workers that stalled, including those of
+// processes that died.
+package heartbeat
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+)
+
+// Worker is a background job with the Start/Stop lifecycle of
+// bootstrap.Worker.
+type Worker interface {
+	Start()
+	Stop()
+}
+
+// Pulse is beaten by a worker once per pass of its loop.
+type Pulse interface {
+	Beat()
+}
+
+// Nop is a Pulse that records nothing, for workers run without a monitor.
+var Nop Pulse = nopPulse{}
+
+type nopPulse struct{}
+
+func (nopPulse) Beat() {}
+
+// Store persists heartbeats.
+type Store interface {
+	RecordHeartbeats(ctx context.Context, beats []domain.WorkerHeartbeat) error
+	ListHeartbeats(ctx context.Context) ([]domain.WorkerHeartbeat, error)
+	// ClaimAlert marks the stall and restarts of h as alerted, reporting
+	// false when they already were or h changed since it was read.
+	ClaimAlert(ctx context.Context, h domain.WorkerHeartbeat, at time.Time) (bool, error)
+	PruneHeartbeats(ctx context.Context, before time.Time) (int, error)
+}
+
+// Monitor tracks the workers of one process.
+type Monitor struct {
+	store    Store
+	service  string
+	instance string
+	cfg      config.HeartbeatConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	mu      sync.Mutex
+	workers []*watched
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// watched is one worker. Each start or restart is a new generation, so
+// beats from an instance the monitor gave up on are ignored.
+type watched struct {
+	name       string
+	interval   time.Duration
+	build      func(Pulse) Worker // nil for tracked workers
+	current    Worker
+	generation int
+	restarts   int
+	startedAt  time.Time
+	lastBeat   time.Time
+	// since is when the current generation was last known alive: its last
+	// beat or, if it has not beaten yet, its start.
+	since     time.Time
+	warnedAt  time.Time
+	stoppedAt *time.Time
+}
+
+// NewMonitor tracks the workers service runs in this process.
+func NewMonitor(store Store, service string, cfg config.HeartbeatConfig, log logger.Logger) *Monitor {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &Monitor{
+		store:    store,
+		service:  service,
+		instance: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		cfg:      cfg,
+		logger:   log,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Watch returns a worker that runs build's worker and is restarted from
+// build when it misses the configured number of beats. Only watch workers
+// whose passes are safe to run twice, since a stalled instance may still
+// be running when its replacement starts. interval is how often the
+// worker beats.
+func (m *Monitor) Watch(name string, interval time.Duration, build func(Pulse) Worker) Worker {
+	if !m.cfg.Enabled {
+		return build(Nop)
+	}
+	w := &watched{name: name, interval: interval, build: build}
+	m.mu.Lock()
+	m.workers = append(m.workers, w)
+	m.mu.Unlock()
+	return &handle{m: m, w: w}
+}
+
+// Track returns the pulse of a worker the monitor only reports on, for
+// workers that are not safe to restart.
+func (m *Monitor) Track(name string, interval time.Duration) Pulse {
+	if !m.cfg.Enabled {
+		return Nop
+	}
+	now := m.now()
+	w := &watched{name: name, interval: interval, generation: 1, startedAt: now, lastBeat: now, since: now}
+	m.mu.Lock()
+	m.workers = append(m.workers, w)
+	m.mu.Unlock()
+	return &pulse{m: m, w: w, generation: 1}
+}
+
+type pulse struct {
+	m          *Monitor
+	w          *watched
+	generation int
+}
+
+func (p *pulse) Beat() {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+	if p.w.generation == p.generation {
+		p.w.lastBeat = p.m.now()
+		p.w.since = p.w.lastBeat
+	}
+}
+
+// handle starts and stops a watched worker for its owner.
+type handle struct {
+	m *Monitor
+	w *watched
+}
+
+func (h *handle) Start() {
+	h.m.mu.Lock()
+	now := h.m.now()
+	h.w.generation++
+	h.w.current = h.w.build(&pulse{m: h.m, w: h.w, generation: h.w.generation})
+	h.w.startedAt, h.w.lastBeat, h.w.since, h.w.stoppedAt = now, now, now, nil
+	current := h.w.current
+	h.m.mu.Unlock()
+	current.Start()
+}
+
+func (h *handle) Stop() {
+	h.m.mu.Lock()
+	now := h.m.now()
+	h.w.stoppedAt = &now
+	current := h.w.current
+	h.m.mu.Unlock()
+	if current != nil {
+		current.Stop()
+	}
+}
+
+// Start checks and flushes the workers every flush interval until Stop.
+func (m *Monitor) Start() {
+	if !m.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(m.cfg.FlushInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.Check()
+				ctx, cancel := context.WithTimeout(context.Background(), m.cfg.FlushInterval)
+				m.flush(ctx)
+				cancel()
+			}
+		}
+	}()
+	m.logger.Info("Worker heartbeat monitor started", map[string]interface{}{"instance": m.instance})
+}
+
+// Stop marks every worker stopped and flushes them one last time, so a
+// clean shutdown is not mistaken for a stall.
+func (m *Monitor) Stop() {
+	if !m.cfg.Enabled {
+		return
+	}
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		m.mu.Lock()
+		now := m.now()
+		for _, w := range m.workers {
+			if w.stoppedAt == nil {
+				w.stoppedAt = &now
+			}
+		}
+		m.mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		m.flush(ctx)
+	})
+}
+
+// Check restarts the watched workers that missed the configured number of
+// beats, when restarts are enabled, and logs the tracked ones.
+func (m *Monitor) Check() {
+	m.mu.Lock()
+	now := m.now()
+	var restart []*watched
+	for _, w := range m.workers {
+		if w.stoppedAt != nil || w.generation == 0 || !m.stalled(w, now) {
+			continue
+		}
+		if w.build != nil && m.cfg.Restart {
+			restart = append(restart, w)
+			continue
+		}
+		if !w.warnedAt.Equal(w.since) {
+			w.warnedAt = w.since
+			m.logger.Warn("Background worker stalled", map[string]interface{}{
+				"worker":    w.name,
+				"last_beat": w.lastBeat,
+			})
+		}
+	}
+	m.mu.Unlock()
+
+	for _, w := range restart {
+		m.restart(w)
+	}
+}
+
+func (m *Monitor) stalled(w *watched, now time.Time) bool {
+	return now.Sub(w.since) > w.interval*time.Duration(m.cfg.MissedBeats)
+}
+
+// restart stops w's current instance and starts a fresh one. The old
+// instance's goroutine may be stuck and outlive Stop; its beats no longer
+// count. The last beat is kept, so the stall stays visible until the new
+// instance beats, and the restart itself is alerted on by the Watchdog.
+func (m *Monitor) restart(w *watched) {
+	m.mu.Lock()
+	if w.stoppedAt != nil {
+		m.mu.Unlock()
+		return
+	}
+	lastBeat := w.lastBeat
+	old := w.current
+	w.generation++
+	w.restarts++
+	w.current = w.build(&pulse{m: m, w: w, generation: w.generation})
+	w.since = m.now()
+	current, restarts := w.current, w.restarts
+	m.mu.Unlock()
+
+	m.logger.Warn("Restarting stalled background worker", map[string]interface{}{
+		"worker":    w.name,
+		"last_beat": lastBeat,
+		"restarts":  restarts,
+	})
+	old.Stop()
+	current.Start()
+}
+
+// Heartbeats returns the workers' current state.
+func (m *Monitor) Heartbeats() []domain.WorkerHeartbeat {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	beats := make([]domain.WorkerHeartbeat, 0, len(m.workers))
+	for _, w := range m.workers {
+		if w.generation == 0 {
+			continue
+		}
+		seconds := int(w.interval / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		beats = append(beats, domain.WorkerHeartbeat{
+			Service:         m.service,
+			Worker:          w.name,
+			Instance:        m.instance,
+			IntervalSeconds: seconds,
+			Restartable:     w.build != nil && m.cfg.Restart,
+			Restarts:        w.restarts,
+			StartedAt:       w.startedAt,
+			LastBeatAt:      w.lastBeat,
+			StoppedAt:       w.stoppedAt,
+		})
+	}
+	return beats
+}
+
+func (m *Monitor) flush(ctx context.Context) {
+	beats := m.Heartbeats()
+	if len(beats) == 0 {
+		return
+	}
+	if err := m.store.RecordHeartbeats(ctx, beats); err != nil {
+		m.logger.Error("Failed to record worker heartbeats", map[string]interface{}{"error": err.Error()})
+	}
+}
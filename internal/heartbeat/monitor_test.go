@@ -0,0 +1,176 @@
+package heartbeat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore keeps heartbeats the way the postgres repository does.
+type memStore struct {
+	beats map[string]*domain.WorkerHeartbeat
+}
+
+func newMemStore() *memStore {
+	return &memStore{beats: map[string]*domain.WorkerHeartbeat{}}
+}
+
+func key(h domain.WorkerHeartbeat) string {
+	return h.Service + "/" + h.Worker + "/" + h.Instance
+}
+
+func (s *memStore) RecordHeartbeats(ctx context.Context, beats []domain.WorkerHeartbeat) error {
+	for _, h := range beats {
+		h := h
+		if old, ok := s.beats[key(h)]; ok {
+			h.AlertedAt, h.AlertedRestarts = old.AlertedAt, old.AlertedRestarts
+		}
+		h.UpdatedAt = time.Now()
+		s.beats[key(h)] = &h
+	}
+	return nil
+}
+
+func (s *memStore) ListHeartbeats(ctx context.Context) ([]domain.WorkerHeartbeat, error) {
+	var out []domain.WorkerHeartbeat
+	for _, h := range s.beats {
+		out = append(out, *h)
+	}
+	return out, nil
+}
+
+func (s *memStore) ClaimAlert(ctx context.Context, h domain.WorkerHeartbeat, at time.Time) (bool, error) {
+	cur := s.beats[key(h)]
+	if !cur.LastBeatAt.Equal(h.LastBeatAt) || cur.Restarts != h.Restarts || cur.StoppedAt != nil {
+		return false, nil
+	}
+	if cur.AlertedAt != nil && !cur.AlertedAt.Before(cur.LastBeatAt) && cur.AlertedRestarts >= cur.Restarts {
+		return false, nil
+	}
+	cur.AlertedAt, cur.AlertedRestarts = &at, cur.Restarts
+	return true, nil
+}
+
+func (s *memStore) PruneHeartbeats(ctx context.Context, before time.Time) (int, error) {
+	n := 0
+	for k, h := range s.beats {
+		if h.UpdatedAt.Before(before) {
+			delete(s.beats, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type fakeWorker struct {
+	pulse   Pulse
+	started bool
+	stopped bool
+}
+
+func (w *fakeWorker) Start() { w.started = true }
+func (w *fakeWorker) Stop()  { w.stopped = true }
+
+func testConfig() config.HeartbeatConfig {
+	return config.HeartbeatConfig{Enabled: true, FlushInterval: 15 * time.Second, MissedBeats: 3, Restart: true, Alerts: true}
+}
+
+func newTestMonitor(store Store, clock *time.Time) *Monitor {
+	m := NewMonitor(store, "payment", testConfig(), logger.NewNop())
+	m.now = func() time.Time { return *clock }
+	return m
+}
+
+func TestMonitor_RestartsStalledWorker(t *testing.T) {
+	now := time.Now()
+	m := newTestMonitor(newMemStore(), &now)
+	var built []*fakeWorker
+	w := m.Watch("escrow_expiry", time.Minute, func(p Pulse) Worker {
+		fw := &fakeWorker{pulse: p}
+		built = append(built, fw)
+		return fw
+	})
+	w.Start()
+	require.Len(t, built, 1)
+	assert.True(t, built[0].started)
+
+	now = now.Add(2 * time.Minute)
+	built[0].pulse.Beat()
+	now = now.Add(3 * time.Minute)
+	m.Check()
+	require.Len(t, built, 1, "three intervals without a beat are not yet a stall")
+
+	now = now.Add(time.Second)
+	m.Check()
+	require.Len(t, built, 2)
+	assert.True(t, built[0].stopped)
+	assert.True(t, built[1].started)
+
+	beats := m.Heartbeats()
+	require.Len(t, beats, 1)
+	assert.Equal(t, 1, beats[0].Restarts)
+	assert.Equal(t, now.Add(-3*time.Minute-time.Second), beats[0].LastBeatAt, "the last beat is kept until the new instance beats")
+
+	// The stalled instance waking up does not count as the worker beating.
+	now = now.Add(time.Minute)
+	built[0].pulse.Beat()
+	assert.Equal(t, now.Add(-4*time.Minute-time.Second), m.Heartbeats()[0].LastBeatAt)
+	built[1].pulse.Beat()
+	assert.Equal(t, now, m.Heartbeats()[0].LastBeatAt)
+
+	w.Stop()
+	assert.True(t, built[1].stopped)
+	now = now.Add(time.Hour)
+	m.Check()
+	assert.Len(t, built, 2, "a stopped worker is not restarted")
+}
+
+func TestMonitor_TrackedWorkersAreNotRestarted(t *testing.T) {
+	now := time.Now()
+	m := newTestMonitor(newMemStore(), &now)
+	m.Track("deposit_listener", 10*time.Second)
+
+	now = now.Add(time.Hour)
+	m.Check()
+	beats := m.Heartbeats()
+	require.Len(t, beats, 1)
+	assert.False(t, beats[0].Restartable)
+	assert.Equal(t, 0, beats[0].Restarts)
+}
+
+func TestMonitor_StopFlushesWorkersAsStopped(t *testing.T) {
+	now := time.Now()
+	store := newMemStore()
+	m := newTestMonitor(store, &now)
+	m.Track("deposit_listener", 10*time.Second).Beat()
+	m.Start()
+	m.Stop()
+
+	beats, _ := store.ListHeartbeats(context.Background())
+	require.Len(t, beats, 1)
+	assert.Equal(t, "payment", beats[0].Service)
+	assert.NotNil(t, beats[0].StoppedAt)
+	assert.Equal(t, domain.WorkerStopped, Status(beats[0], now.Add(time.Hour), testConfig()))
+}
+
+func TestMonitor_DisabledRunsWorkersUnwatched(t *testing.T) {
+	cfg := testConfig()
+	cfg.Enabled = false
+	m := NewMonitor(newMemStore(), "payment", cfg, logger.NewNop())
+	var got Pulse
+	w := m.Watch("escrow_expiry", time.Minute, func(p Pulse) Worker {
+		got = p
+		return &fakeWorker{pulse: p}
+	})
+	_, ok := w.(*fakeWorker)
+	assert.True(t, ok)
+	assert.Equal(t, Nop, got)
+	assert.Empty(t, m.Heartbeats())
+}
@@ -0,0 +1,206 @@
+package heartbeat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// AdminDirectory lists the admins alerts go to.
+type AdminDirectory interface {
+	ListAdminIDs(ctx context.Context) ([]uuid.UUID, error)
+}
+
+// Notifier delivers alerts. Satisfied by notification.Service.
+type Notifier interface {
+	SendRaw(ctx context.Context, n *notification.Notification) error
+}
+
+// Status classifies h as seen at now. Beats reach the store once per flush
+// interval, so that much lag is allowed on top of the worker's own
+// interval.
+func Status(h domain.WorkerHeartbeat, now time.Time, cfg config.HeartbeatConfig) domain.WorkerStatus {
+	if h.StoppedAt != nil {
+		return domain.WorkerStopped
+	}
+	silent := now.Sub(h.LastBeatAt) - cfg.FlushInterval
+	switch {
+	case silent > h.Interval()*time.Duration(cfg.MissedBeats):
+		return domain.WorkerStalled
+	case silent > h.Interval():
+		return domain.WorkerLate
+	}
+	return domain.WorkerHealthy
+}
+
+// Watchdog alerts admins to stalled workers across every process.
+type Watchdog struct {
+	store    Store
+	admins   AdminDirectory
+	notifier Notifier
+	cfg      config.HeartbeatConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewWatchdog(store Store, cfg config.HeartbeatConfig, log logger.Logger) *Watchdog {
+	return &Watchdog{store: store, cfg: cfg, logger: log, now: time.Now, stop: make(chan struct{})}
+}
+
+// WithAlerts sends stalled workers to every admin. Without it stalls are
+// only logged and listed.
+func (w *Watchdog) WithAlerts(admins AdminDirectory, notifier Notifier) *Watchdog {
+	w.admins = admins
+	w.notifier = notifier
+	return w
+}
+
+// Start checks every flush interval until Stop is called.
+func (w *Watchdog) Start() {
+	if !w.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), w.cfg.FlushInterval)
+				if _, err := w.Check(ctx); err != nil {
+					w.logger.Error("Worker heartbeat check failed", map[string]interface{}{"error": err.Error()})
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// Check alerts admins to workers that stalled since they last beat or were
+// restarted since the last alert, and prunes workers of processes gone for
+// longer than the retention. It returns the workers it alerted on; each is
+// claimed in the store first, so one alert goes out however many
+// processes run a watchdog.
+func (w *Watchdog) Check(ctx context.Context) ([]domain.WorkerHeartbeat, error) {
+	beats, err := w.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var stalled []domain.WorkerHeartbeat
+	for _, h := range beats {
+		if h.Status != domain.WorkerStalled && (h.Status == domain.WorkerStopped || h.Restarts <= h.AlertedRestarts) {
+			continue
+		}
+		claimed, err := w.store.ClaimAlert(ctx, h, w.now())
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			stalled = append(stalled, h)
+		}
+	}
+	if len(stalled) > 0 {
+		for _, h := range stalled {
+			w.logger.Warn("Background worker stalled", map[string]interface{}{
+				"service":   h.Service,
+				"worker":    h.Worker,
+				"instance":  h.Instance,
+				"last_beat": h.LastBeatAt,
+				"restarts":  h.Restarts,
+			})
+		}
+		w.alert(ctx, stalled)
+	}
+
+	if w.cfg.Retention > 0 {
+		pruned, err := w.store.PruneHeartbeats(ctx, w.now().Add(-w.cfg.Retention))
+		if err != nil {
+			return stalled, err
+		}
+		if pruned > 0 {
+			w.logger.Info("Pruned worker heartbeats", map[string]interface{}{"count": pruned})
+		}
+	}
+	return stalled, nil
+}
+
+// List returns every process's workers with their status.
+func (w *Watchdog) List(ctx context.Context) ([]domain.WorkerHeartbeat, error) {
+	beats, err := w.store.ListHeartbeats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := w.now()
+	for i := range beats {
+		beats[i].Status = Status(beats[i], now, w.cfg)
+	}
+	return beats, nil
+}
+
+// alert sends one ops alert listing the stalled and restarted workers to
+// every admin.
+func (w *Watchdog) alert(ctx context.Context, stalled []domain.WorkerHeartbeat) {
+	if w.notifier == nil || w.admins == nil {
+		return
+	}
+	admins, err := w.admins.ListAdminIDs(ctx)
+	if err != nil {
+		w.logger.Error("Failed to list alert recipients", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	subject, body := describeStalls(stalled, w.now())
+	for _, id := range admins {
+		err := w.notifier.SendRaw(ctx, &notification.Notification{
+			ID:        uuid.New(),
+			UserID:    id,
+			Type:      "OPS_ALERT",
+			Channel:   notification.ChannelEmail,
+			Priority:  notification.PriorityUrgent,
+			Subject:   subject,
+			Body:      body,
+			Metadata:  map[string]interface{}{"stalled_workers": len(stalled)},
+			CreatedAt: w.now(),
+		})
+		if err != nil {
+			w.logger.Error("Failed to send ops alert", map[string]interface{}{"error": err.Error(), "user_id": id})
+		}
+	}
+}
+
+func describeStalls(stalled []domain.WorkerHeartbeat, now time.Time) (string, string) {
+	subject := fmt.Sprintf("Ops alert: %d background worker stalled", len(stalled))
+	if len(stalled) != 1 {
+		subject = fmt.Sprintf("Ops alert: %d background workers stalled", len(stalled))
+	}
+	var b strings.Builder
+	for _, h := range stalled {
+		fmt.Fprintf(&b, "%s/%s on %s: last beat %s ago, expected every %s",
+			h.Service, h.Worker, h.Instance, now.Sub(h.LastBeatAt).Round(time.Second), h.Interval())
+		switch {
+		case h.Restarts == 1:
+			b.WriteString("; restarted once")
+		case h.Restarts > 1:
+			fmt.Fprintf(&b, "; restarted %d times", h.Restarts)
+		}
+		b.WriteString("\n")
+	}
+	return subject, b.String()
+}
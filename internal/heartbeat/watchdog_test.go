@@ -0,0 +1,119 @@
+package heartbeat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type admins []uuid.UUID
+
+func (a admins) ListAdminIDs(ctx context.Context) ([]uuid.UUID, error) { return a, nil }
+
+type sentAlerts []*notification.Notification
+
+func (s *sentAlerts) SendRaw(ctx context.Context, n *notification.Notification) error {
+	*s = append(*s, n)
+	return nil
+}
+
+func TestStatus(t *testing.T) {
+	cfg := testConfig()
+	now := time.Now()
+	h := domain.WorkerHeartbeat{IntervalSeconds: 60}
+	for _, tc := range []struct {
+		silent time.Duration
+		want   domain.WorkerStatus
+	}{
+		{time.Minute, domain.WorkerHealthy},
+		{time.Minute + cfg.FlushInterval, domain.WorkerHealthy},
+		{2 * time.Minute, domain.WorkerLate},
+		{3*time.Minute + cfg.FlushInterval + time.Second, domain.WorkerStalled},
+	} {
+		h.LastBeatAt = now.Add(-tc.silent)
+		assert.Equal(t, tc.want, Status(h, now, cfg), tc.silent.String())
+	}
+}
+
+func TestWatchdog_AlertsOncePerStall(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := newMemStore()
+	var sent sentAlerts
+	ops := admins{uuid.New()}
+	wd := NewWatchdog(store, testConfig(), logger.NewNop()).WithAlerts(ops, &sent)
+	wd.now = func() time.Time { return now }
+
+	stalled := domain.WorkerHeartbeat{Service: "settlement", Worker: "deposit_listener", Instance: "host-1", IntervalSeconds: 10, StartedAt: now, LastBeatAt: now}
+	healthy := domain.WorkerHeartbeat{Service: "payment", Worker: "escrow_expiry", Instance: "host-2", IntervalSeconds: 600, StartedAt: now, LastBeatAt: now}
+	require.NoError(t, store.RecordHeartbeats(ctx, []domain.WorkerHeartbeat{stalled, healthy}))
+
+	now = now.Add(time.Minute)
+	alerted, err := wd.Check(ctx)
+	require.NoError(t, err)
+	require.Len(t, alerted, 1)
+	assert.Equal(t, "deposit_listener", alerted[0].Worker)
+	require.Len(t, sent, 1)
+	assert.Equal(t, ops[0], sent[0].UserID)
+	assert.Equal(t, "OPS_ALERT", sent[0].Type)
+	assert.Equal(t, "Ops alert: 1 background worker stalled", sent[0].Subject)
+	assert.Contains(t, sent[0].Body, "settlement/deposit_listener on host-1: last beat 1m0s ago, expected every 10s")
+
+	// Another watchdog, or the next check, does not alert the same stall.
+	_, err = wd.Check(ctx)
+	require.NoError(t, err)
+	assert.Len(t, sent, 1)
+
+	// Once the worker beats again, a new stall is alerted again.
+	stalled.LastBeatAt = now.Add(time.Second)
+	require.NoError(t, store.RecordHeartbeats(ctx, []domain.WorkerHeartbeat{stalled}))
+	now = now.Add(time.Minute)
+	_, err = wd.Check(ctx)
+	require.NoError(t, err)
+	assert.Len(t, sent, 2)
+}
+
+func TestWatchdog_AlertsRestarts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := newMemStore()
+	var sent sentAlerts
+	wd := NewWatchdog(store, testConfig(), logger.NewNop()).WithAlerts(admins{uuid.New()}, &sent)
+	wd.now = func() time.Time { return now }
+
+	h := domain.WorkerHeartbeat{Service: "payment", Worker: "saga_recovery", Instance: "host-1", IntervalSeconds: 60, Restartable: true, Restarts: 1, StartedAt: now, LastBeatAt: now}
+	require.NoError(t, store.RecordHeartbeats(ctx, []domain.WorkerHeartbeat{h}))
+
+	_, err := wd.Check(ctx)
+	require.NoError(t, err)
+	require.Len(t, sent, 1, "a restarted worker is alerted on even once healthy again")
+	assert.Contains(t, sent[0].Body, "restarted once")
+
+	_, err = wd.Check(ctx)
+	require.NoError(t, err)
+	assert.Len(t, sent, 1)
+}
+
+func TestWatchdog_PrunesDeadProcesses(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	cfg := testConfig()
+	cfg.Retention = time.Hour
+	wd := NewWatchdog(store, cfg, logger.NewNop())
+	now := time.Now()
+	stopped := now.Add(-2 * time.Hour)
+	require.NoError(t, store.RecordHeartbeats(ctx, []domain.WorkerHeartbeat{{Service: "payment", Worker: "escrow_expiry", Instance: "old", IntervalSeconds: 60, LastBeatAt: stopped, StoppedAt: &stopped}}))
+	store.beats["payment/escrow_expiry/old"].UpdatedAt = stopped
+
+	_, err := wd.Check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, store.beats)
+}
@@ -0,0 +1,228 @@
+// Package integrity checks the database for problems its constraints cannot
+// catch: completed transactions missing from the ledger, KYC documents whose
+// files are gone, wallets left without an owner, references across schemas
+// that point nowhere, and transactions, settlements and documents stuck in
+// flight.
+//
+// Checks run on a schedule and on demand. Each problem found becomes an
+// open finding that later runs refresh while it persists and resolve once
+// it is gone, so ops work from one list rather than a report per run.
+package integrity
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// ErrRunInProgress is returned when a run is requested while another is
+// still going.
+var ErrRunInProgress = errors.New("an integrity run is already in progress")
+
+// Repository runs the checks' queries and keeps findings and runs.
+type Repository interface {
+	TransactionsWithoutLedger(ctx context.Context, before time.Time) ([]domain.IntegrityFinding, error)
+	WalletsWithoutUsers(ctx context.Context) ([]domain.IntegrityFinding, error)
+	DanglingReferences(ctx context.Context) ([]domain.IntegrityFinding, error)
+	StalePending(ctx context.Context, before time.Time) ([]domain.IntegrityFinding, error)
+	CurrentDocuments(ctx context.Context) ([]domain.KYCDocument, error)
+
+	RecordFindings(ctx context.Context, check domain.IntegrityCheck, found []domain.IntegrityFinding, seenAt time.Time) (opened, resolved int, err error)
+	CountOpenFindings(ctx context.Context) (int, error)
+	ListFindings(ctx context.Context, check domain.IntegrityCheck, status domain.IntegrityFindingStatus, limit, offset int) ([]domain.IntegrityFinding, int, error)
+	CreateRun(ctx context.Context, run *domain.IntegrityRun) error
+	ListRuns(ctx context.Context, limit int) ([]domain.IntegrityRun, error)
+}
+
+// DocumentFiles opens the files behind KYC document URLs. Satisfied by
+// compliance.UploadDir.
+type DocumentFiles interface {
+	Open(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+type Service struct {
+	repo   Repository
+	files  DocumentFiles
+	cfg    config.IntegrityConfig
+	logger logger.Logger
+	now    func() time.Time
+
+	running  sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, cfg config.IntegrityConfig, log logger.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		cfg:    cfg,
+		logger: log,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+}
+
+// WithDocumentFiles checks that the files of current KYC documents are
+// still in storage. Without it the document check is skipped.
+func (s *Service) WithDocumentFiles(files DocumentFiles) *Service {
+	s.files = files
+	return s
+}
+
+// Start runs the checks every configured interval until Stop is called.
+func (s *Service) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Interval)
+				if _, err := s.Run(ctx); err != nil && !errors.Is(err, ErrRunInProgress) {
+					s.logger.Error("Integrity run failed", map[string]interface{}{"error": err.Error()})
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Run runs every check once and records what it found. A check that fails
+// is listed in the run's Failed and leaves its findings untouched, so an
+// outage does not resolve them.
+func (s *Service) Run(ctx context.Context) (*domain.IntegrityRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer s.running.Unlock()
+
+	run := &domain.IntegrityRun{ID: uuid.New(), StartedAt: s.now(), Failed: []string{}}
+	for _, check := range domain.IntegrityChecks {
+		if check == domain.IntegrityDocumentWithoutFile && s.files == nil {
+			continue
+		}
+		seenAt := s.now()
+		found, err := s.find(ctx, check, seenAt)
+		if err == nil {
+			var opened, resolved int
+			opened, resolved, err = s.repo.RecordFindings(ctx, check, found, seenAt)
+			run.Opened += opened
+			run.Resolved += resolved
+		}
+		if err != nil {
+			s.logger.Error("Integrity check failed", map[string]interface{}{"check": check, "error": err.Error()})
+			run.Failed = append(run.Failed, string(check))
+		}
+	}
+
+	open, err := s.repo.CountOpenFindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	run.Open = open
+	run.FinishedAt = s.now()
+	if err := s.repo.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{
+		"open":     run.Open,
+		"opened":   run.Opened,
+		"resolved": run.Resolved,
+		"failed":   []string(run.Failed),
+	}
+	if run.Opened > 0 || len(run.Failed) > 0 {
+		s.logger.Warn("Integrity run found new problems", fields)
+	} else {
+		s.logger.Info("Integrity run completed", fields)
+	}
+	return run, nil
+}
+
+// find runs one check as of now.
+func (s *Service) find(ctx context.Context, check domain.IntegrityCheck, now time.Time) ([]domain.IntegrityFinding, error) {
+	switch check {
+	case domain.IntegrityTransactionWithoutLedger:
+		return s.repo.TransactionsWithoutLedger(ctx, now.Add(-s.cfg.LedgerGrace))
+	case domain.IntegrityDocumentWithoutFile:
+		return s.documentsWithoutFiles(ctx)
+	case domain.IntegrityWalletWithoutUser:
+		return s.repo.WalletsWithoutUsers(ctx)
+	case domain.IntegrityDanglingReference:
+		return s.repo.DanglingReferences(ctx)
+	case domain.IntegrityStalePending:
+		return s.repo.StalePending(ctx, now.Add(-s.cfg.StaleAfter))
+	}
+	return nil, nil
+}
+
+// documentsWithoutFiles reports current documents with a file that cannot
+// be opened. Documents that were not accepted yet are less severe, as the
+// user can upload them again.
+func (s *Service) documentsWithoutFiles(ctx context.Context) ([]domain.IntegrityFinding, error) {
+	docs, err := s.repo.CurrentDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found []domain.IntegrityFinding
+	for i := range docs {
+		d := &docs[i]
+		for _, u := range []*string{d.FrontImageURL, d.BackImageURL, d.SelfieImageURL} {
+			if u == nil || *u == "" {
+				continue
+			}
+			f, err := s.files.Open(ctx, *u)
+			if err == nil {
+				f.Close()
+				continue
+			}
+			severity := domain.SecuritySeverityMedium
+			if d.ReviewStatus == domain.KYCDocumentAccepted {
+				severity = domain.SecuritySeverityHigh
+			}
+			found = append(found, domain.IntegrityFinding{
+				Check:        domain.IntegrityDocumentWithoutFile,
+				Severity:     severity,
+				ResourceType: "kyc_document",
+				ResourceID:   d.ID.String(),
+				Detail:       d.DocumentType + " document file " + *u + " is missing",
+			})
+			break
+		}
+	}
+	return found, nil
+}
+
+// ListFindings returns findings, most recently seen first. status defaults
+// to open; "all" lists resolved findings too.
+func (s *Service) ListFindings(ctx context.Context, check domain.IntegrityCheck, status domain.IntegrityFindingStatus, limit, offset int) ([]domain.IntegrityFinding, int, error) {
+	switch status {
+	case "":
+		status = domain.IntegrityFindingOpen
+	case "all":
+		status = ""
+	}
+	return s.repo.ListFindings(ctx, check, status, limit, offset)
+}
+
+// ListRuns returns the latest runs, newest first.
+func (s *Service) ListRuns(ctx context.Context, limit int) ([]domain.IntegrityRun, error) {
+	return s.repo.ListRuns(ctx, limit)
+}
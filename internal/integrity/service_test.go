@@ -0,0 +1,246 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) findings(args mock.Arguments) ([]domain.IntegrityFinding, error) {
+	found, _ := args.Get(0).([]domain.IntegrityFinding)
+	return found, args.Error(1)
+}
+
+func (m *MockRepository) TransactionsWithoutLedger(ctx context.Context, before time.Time) ([]domain.IntegrityFinding, error) {
+	return m.findings(m.Called(ctx, before))
+}
+
+func (m *MockRepository) WalletsWithoutUsers(ctx context.Context) ([]domain.IntegrityFinding, error) {
+	return m.findings(m.Called(ctx))
+}
+
+func (m *MockRepository) DanglingReferences(ctx context.Context) ([]domain.IntegrityFinding, error) {
+	return m.findings(m.Called(ctx))
+}
+
+func (m *MockRepository) StalePending(ctx context.Context, before time.Time) ([]domain.IntegrityFinding, error) {
+	return m.findings(m.Called(ctx, before))
+}
+
+func (m *MockRepository) CurrentDocuments(ctx context.Context) ([]domain.KYCDocument, error) {
+	args := m.Called(ctx)
+	docs, _ := args.Get(0).([]domain.KYCDocument)
+	return docs, args.Error(1)
+}
+
+func (m *MockRepository) RecordFindings(ctx context.Context, check domain.IntegrityCheck, found []domain.IntegrityFinding, seenAt time.Time) (int, int, error) {
+	args := m.Called(ctx, check, found, seenAt)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) CountOpenFindings(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ListFindings(ctx context.Context, check domain.IntegrityCheck, status domain.IntegrityFindingStatus, limit, offset int) ([]domain.IntegrityFinding, int, error) {
+	args := m.Called(ctx, check, status, limit, offset)
+	found, _ := args.Get(0).([]domain.IntegrityFinding)
+	return found, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) CreateRun(ctx context.Context, run *domain.IntegrityRun) error {
+	return m.Called(ctx, run).Error(0)
+}
+
+func (m *MockRepository) ListRuns(ctx context.Context, limit int) ([]domain.IntegrityRun, error) {
+	args := m.Called(ctx, limit)
+	runs, _ := args.Get(0).([]domain.IntegrityRun)
+	return runs, args.Error(1)
+}
+
+type MockFiles struct {
+	mock.Mock
+}
+
+func (m *MockFiles) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	args := m.Called(ctx, url)
+	f, _ := args.Get(0).(io.ReadCloser)
+	return f, args.Error(1)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 4, 1, 3, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *MockRepository) {
+	repo := new(MockRepository)
+	s := NewService(repo, config.IntegrityConfig{
+		Enabled: true, Interval: time.Hour, StaleAfter: 24 * time.Hour, LedgerGrace: 15 * time.Minute,
+	}, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo
+}
+
+// clean expects every query check to find nothing, unless the test has
+// already said otherwise, and its findings to be recorded.
+func clean(repo *MockRepository, now time.Time) {
+	repo.On("TransactionsWithoutLedger", ctx, now.Add(-15*time.Minute)).Return(nil, nil).Maybe()
+	repo.On("WalletsWithoutUsers", ctx).Return(nil, nil).Maybe()
+	repo.On("DanglingReferences", ctx).Return(nil, nil).Maybe()
+	repo.On("StalePending", ctx, now.Add(-24*time.Hour)).Return(nil, nil).Maybe()
+	repo.On("RecordFindings", ctx, mock.Anything, mock.Anything, now).Return(0, 0, nil).Maybe()
+}
+
+func finding(resourceType, id string) domain.IntegrityFinding {
+	return domain.IntegrityFinding{Severity: domain.SecuritySeverityMedium, ResourceType: resourceType, ResourceID: id}
+}
+
+func TestRun_RecordsEachCheck(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	wallets := []domain.IntegrityFinding{finding("wallet", "w1"), finding("wallet", "w2")}
+	stale := []domain.IntegrityFinding{finding("settlement", "s1")}
+	repo.On("TransactionsWithoutLedger", ctx, now.Add(-15*time.Minute)).Return(nil, nil).Once()
+	repo.On("WalletsWithoutUsers", ctx).Return(wallets, nil).Once()
+	repo.On("DanglingReferences", ctx).Return(nil, nil).Once()
+	repo.On("StalePending", ctx, now.Add(-24*time.Hour)).Return(stale, nil).Once()
+	repo.On("RecordFindings", ctx, domain.IntegrityTransactionWithoutLedger, ([]domain.IntegrityFinding)(nil), now).Return(0, 1, nil).Once()
+	repo.On("RecordFindings", ctx, domain.IntegrityWalletWithoutUser, wallets, now).Return(2, 0, nil).Once()
+	repo.On("RecordFindings", ctx, domain.IntegrityDanglingReference, ([]domain.IntegrityFinding)(nil), now).Return(0, 0, nil).Once()
+	repo.On("RecordFindings", ctx, domain.IntegrityStalePending, stale, now).Return(0, 0, nil).Once()
+	repo.On("CountOpenFindings", ctx).Return(3, nil).Once()
+	repo.On("CreateRun", ctx, mock.MatchedBy(func(run *domain.IntegrityRun) bool {
+		return run.Opened == 2 && run.Resolved == 1 && run.Open == 3 && len(run.Failed) == 0 &&
+			run.StartedAt.Equal(now) && run.FinishedAt.Equal(now)
+	})).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, run.Open)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "CurrentDocuments", mock.Anything)
+}
+
+func TestRun_FailedCheckKeepsItsFindings(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	repo.On("StalePending", ctx, now.Add(-24*time.Hour)).Return(nil, errors.New("connection reset")).Once()
+	repo.On("RecordFindings", ctx, domain.IntegrityWalletWithoutUser, mock.Anything, now).Return(0, 0, errors.New("deadlock")).Once()
+	clean(repo, now)
+	repo.On("CountOpenFindings", ctx).Return(2, nil).Once()
+	repo.On("CreateRun", ctx, mock.Anything).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{string(domain.IntegrityWalletWithoutUser), string(domain.IntegrityStalePending)}, []string(run.Failed))
+	repo.AssertNotCalled(t, "RecordFindings", ctx, domain.IntegrityStalePending, mock.Anything, mock.Anything)
+	repo.AssertExpectations(t)
+
+	t.Run("the run cannot be counted", func(t *testing.T) {
+		s, repo := newService(&now)
+		clean(repo, now)
+		repo.On("CountOpenFindings", ctx).Return(0, errors.New("connection reset")).Once()
+
+		_, err := s.Run(ctx)
+		assert.Error(t, err)
+		repo.AssertNotCalled(t, "CreateRun", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRun_DocumentsWithoutFiles(t *testing.T) {
+	now := start
+	front, selfie, gone := "/uploads/kyc/front.png", "/uploads/kyc/selfie.png", "/uploads/kyc/gone.png"
+	accepted := domain.KYCDocument{ID: uuid.New(), DocumentType: "passport", FrontImageURL: &front, SelfieImageURL: &gone, ReviewStatus: domain.KYCDocumentAccepted}
+	intact := domain.KYCDocument{ID: uuid.New(), DocumentType: "national_id", FrontImageURL: &front, SelfieImageURL: &selfie, ReviewStatus: domain.KYCDocumentInReview}
+	reupload := domain.KYCDocument{ID: uuid.New(), DocumentType: "national_id", FrontImageURL: &gone, SelfieImageURL: &selfie, ReviewStatus: domain.KYCDocumentInReview}
+
+	t.Run("without storage the check does not run", func(t *testing.T) {
+		s, repo := newService(&now)
+		clean(repo, now)
+		repo.On("CountOpenFindings", ctx).Return(0, nil).Once()
+		repo.On("CreateRun", ctx, mock.Anything).Return(nil).Once()
+
+		_, err := s.Run(ctx)
+		require.NoError(t, err)
+		repo.AssertNotCalled(t, "CurrentDocuments", mock.Anything)
+		repo.AssertNotCalled(t, "RecordFindings", ctx, domain.IntegrityDocumentWithoutFile, mock.Anything, mock.Anything)
+	})
+
+	s, repo := newService(&now)
+	files := new(MockFiles)
+	s.WithDocumentFiles(files)
+	files.On("Open", ctx, front).Return(io.NopCloser(strings.NewReader("")), nil)
+	files.On("Open", ctx, selfie).Return(io.NopCloser(strings.NewReader("")), nil)
+	files.On("Open", ctx, gone).Return(nil, os.ErrNotExist)
+	repo.On("CurrentDocuments", ctx).Return([]domain.KYCDocument{accepted, intact, reupload}, nil).Once()
+	var found []domain.IntegrityFinding
+	repo.On("RecordFindings", ctx, domain.IntegrityDocumentWithoutFile, mock.Anything, now).Run(func(args mock.Arguments) {
+		found = args.Get(2).([]domain.IntegrityFinding)
+	}).Return(2, 0, nil).Once()
+	clean(repo, now)
+	repo.On("CountOpenFindings", ctx).Return(2, nil).Once()
+	repo.On("CreateRun", ctx, mock.Anything).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, run.Opened)
+	repo.AssertExpectations(t)
+
+	require.Len(t, found, 2)
+	assert.Equal(t, accepted.ID.String(), found[0].ResourceID)
+	assert.Equal(t, domain.SecuritySeverityHigh, found[0].Severity, "an accepted document cannot simply be uploaded again")
+	assert.Contains(t, found[0].Detail, gone)
+	assert.Equal(t, reupload.ID.String(), found[1].ResourceID)
+	assert.Equal(t, domain.SecuritySeverityMedium, found[1].Severity)
+	files.AssertNumberOfCalls(t, "Open", 5)
+}
+
+func TestRun_OneAtATime(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	s.running.Lock()
+	_, err := s.Run(ctx)
+	assert.ErrorIs(t, err, ErrRunInProgress)
+	s.running.Unlock()
+	repo.AssertNotCalled(t, "CreateRun", mock.Anything, mock.Anything)
+}
+
+func TestListFindings(t *testing.T) {
+	now := start
+	tests := []struct {
+		status domain.IntegrityFindingStatus
+		want   domain.IntegrityFindingStatus
+	}{
+		{"", domain.IntegrityFindingOpen},
+		{"all", ""},
+		{domain.IntegrityFindingResolved, domain.IntegrityFindingResolved},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			s, repo := newService(&now)
+			repo.On("ListFindings", ctx, domain.IntegrityWalletWithoutUser, tt.want, 50, 0).Return(nil, 0, nil).Once()
+			_, _, err := s.ListFindings(ctx, domain.IntegrityWalletWithoutUser, tt.status, 50, 0)
+			require.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
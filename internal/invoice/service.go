@@ -0,0 +1,521 @@
+// Package invoice lets merchants request money: they issue invoices that
+// are paid through a shareable link, each payment being an ordinary payment
+// from the payer to the merchant.
+package invoice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidInvoice = errors.New("invalid invoice")
+	ErrNotFound       = errors.New("invoice not found")
+	// ErrNotMerchant is returned when someone other than a merchant issues
+	// an invoice.
+	ErrNotMerchant = errors.New("only merchant accounts can issue invoices")
+	// ErrInvalidStatus is returned for a change the invoice's status does
+	// not allow, such as sending one already paid.
+	ErrInvalidStatus = errors.New("invoice status does not allow this")
+	// ErrNotPayable is returned for an invoice that cannot be paid: not
+	// sent, already paid, expired or cancelled.
+	ErrNotPayable = errors.New("invoice cannot be paid")
+	// ErrReferenceExists is returned when the merchant already has an
+	// invoice with the reference.
+	ErrReferenceExists = errors.New("an invoice with this reference already exists")
+)
+
+// Repository persists invoices.
+type Repository interface {
+	// Create returns ErrReferenceExists when the merchant already has an
+	// invoice with its reference.
+	Create(ctx context.Context, inv *domain.MerchantInvoice) error
+	Get(ctx context.Context, id uuid.UUID) (*domain.MerchantInvoice, error)
+	GetByToken(ctx context.Context, token string) (*domain.MerchantInvoice, error)
+	// List pages through the merchant's invoices, newest first, optionally
+	// only those with status.
+	List(ctx context.Context, merchantID uuid.UUID, status domain.MerchantInvoiceStatus, limit, offset int) ([]domain.MerchantInvoice, int, error)
+	// Transition saves inv if it still has status from, reporting false when
+	// it no longer had, so that two callers cannot both change it.
+	Transition(ctx context.Context, inv *domain.MerchantInvoice, from domain.MerchantInvoiceStatus) (bool, error)
+	// ExpireDue closes up to limit sent invoices past their expiry and
+	// returns them.
+	ExpireDue(ctx context.Context, now time.Time, limit int) ([]domain.MerchantInvoice, error)
+}
+
+type Users interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// Wallets finds the merchant's wallet in an invoice's currency.
+type Wallets interface {
+	FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error)
+}
+
+// StepUp is the payer's re-authentication, for payments that need it.
+type StepUp struct {
+	Password string `json:"password,omitempty"`
+	TOTPCode string `json:"totp_code,omitempty"`
+}
+
+// Payment pays an invoice.
+type Payment struct {
+	PayerID    uuid.UUID
+	MerchantID uuid.UUID
+	Amount     decimal.Decimal
+	Currency   domain.Currency
+	// Reference is unique to the invoice, so paying it again returns the
+	// payment already made.
+	Reference   string
+	Description string
+	Metadata    domain.Metadata
+	StepUp      *StepUp
+}
+
+// Payer makes invoice payments with every check of any other payment.
+type Payer interface {
+	Pay(ctx context.Context, p *Payment) (*domain.Transaction, error)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
+const (
+	// EventInvoiceReceived tells the payer an invoice was addressed to them.
+	EventInvoiceReceived = "INVOICE_RECEIVED"
+	// EventInvoicePaid tells the merchant an invoice was paid.
+	EventInvoicePaid = "INVOICE_PAID"
+)
+
+const (
+	defaultExpiry         = 7 * 24 * time.Hour
+	defaultMaxExpiry      = 90 * 24 * time.Hour
+	defaultExpiryInterval = time.Minute
+	expiryBatchSize       = 100
+	maxReference          = 64
+	maxMemo               = 255
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type Service struct {
+	repo          Repository
+	users         Users
+	wallets       Wallets
+	payer         Payer
+	notifier      Notifier
+	logger        logger.Logger
+	payBaseURL    string
+	defaultExpiry time.Duration
+	maxExpiry     time.Duration
+	interval      time.Duration
+	now           func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, users Users, wallets Wallets, payer Payer, notifier Notifier, cfg config.InvoiceConfig, log logger.Logger) *Service {
+	s := &Service{
+		repo:          repo,
+		users:         users,
+		wallets:       wallets,
+		payer:         payer,
+		notifier:      notifier,
+		logger:        log,
+		payBaseURL:    strings.TrimRight(cfg.PayBaseURL, "/"),
+		defaultExpiry: defaultExpiry,
+		maxExpiry:     defaultMaxExpiry,
+		interval:      defaultExpiryInterval,
+		now:           time.Now,
+		stop:          make(chan struct{}),
+	}
+	if cfg.DefaultExpiry > 0 {
+		s.defaultExpiry = cfg.DefaultExpiry
+	}
+	if cfg.MaxExpiry > 0 {
+		s.maxExpiry = cfg.MaxExpiry
+	}
+	if cfg.ExpiryInterval > 0 {
+		s.interval = cfg.ExpiryInterval
+	}
+	return s
+}
+
+// CreateRequest is an invoice a merchant issues.
+type CreateRequest struct {
+	MerchantID uuid.UUID       `json:"-"`
+	Amount     decimal.Decimal `json:"amount"`
+	Currency   domain.Currency `json:"currency"`
+	Memo       string          `json:"memo"`
+	Reference  string          `json:"reference"`
+	// PayerID, when set, is the only user who may pay the invoice.
+	PayerID *uuid.UUID `json:"payer_id"`
+	// ExpiresAt defaults to the configured expiry from now.
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Send sends the invoice at once instead of keeping it as a draft.
+	Send bool `json:"send"`
+}
+
+// Create issues an invoice from a merchant, as a draft or already sent. It
+// is paid into the merchant's wallet in its currency.
+func (s *Service) Create(ctx context.Context, req *CreateRequest) (*domain.MerchantInvoice, error) {
+	merchant, err := s.users.FindByID(ctx, req.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.UserType != domain.UserTypeMerchant || !merchant.IsActive {
+		return nil, ErrNotMerchant
+	}
+
+	now := s.now().UTC()
+	reference := strings.TrimSpace(req.Reference)
+	memo := strings.TrimSpace(req.Memo)
+	expiresAt := now.Add(s.defaultExpiry)
+	if req.ExpiresAt != nil {
+		expiresAt = req.ExpiresAt.UTC()
+	}
+	switch {
+	case !req.Amount.IsPositive():
+		return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidInvoice)
+	case !req.Amount.Equal(req.Amount.Round(2)):
+		return nil, fmt.Errorf("%w: amount must have at most two decimal places", ErrInvalidInvoice)
+	case !currencyCode.MatchString(string(req.Currency)):
+		return nil, fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidInvoice)
+	case utf8.RuneCountInString(reference) > maxReference:
+		return nil, fmt.Errorf("%w: reference must be at most %d characters", ErrInvalidInvoice, maxReference)
+	case utf8.RuneCountInString(memo) > maxMemo:
+		return nil, fmt.Errorf("%w: memo must be at most %d characters", ErrInvalidInvoice, maxMemo)
+	case !expiresAt.After(now):
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidInvoice)
+	case expiresAt.After(now.Add(s.maxExpiry)):
+		return nil, fmt.Errorf("%w: expires_at may be at most %s away", ErrInvalidInvoice, s.maxExpiry)
+	}
+	if req.PayerID != nil {
+		if *req.PayerID == req.MerchantID {
+			return nil, fmt.Errorf("%w: you cannot invoice yourself", ErrInvalidInvoice)
+		}
+		payer, err := s.users.FindByID(ctx, *req.PayerID)
+		if err != nil || !payer.IsActive {
+			return nil, fmt.Errorf("%w: payer_id is not an active user", ErrInvalidInvoice)
+		}
+	}
+	wallet, err := s.wallets.FindByUserAndCurrency(ctx, req.MerchantID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, fmt.Errorf("%w: you have no %s wallet to be paid into", ErrInvalidInvoice, req.Currency)
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &domain.MerchantInvoice{
+		ID:         uuid.New(),
+		MerchantID: req.MerchantID,
+		WalletID:   wallet.ID,
+		PayerID:    req.PayerID,
+		Reference:  reference,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+		Memo:       memo,
+		Status:     domain.MerchantInvoiceDraft,
+		Token:      token,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if req.Send {
+		inv.Status = domain.MerchantInvoiceSent
+		inv.SentAt = &now
+	}
+	if err := s.repo.Create(ctx, inv); err != nil {
+		return nil, err
+	}
+	if req.Send {
+		s.notifyPayer(ctx, inv, merchant)
+	}
+	return inv, nil
+}
+
+// PaymentLink is the shareable link an invoice is paid through. It can be
+// shown as a QR code as is.
+func (s *Service) PaymentLink(inv *domain.MerchantInvoice) string {
+	return s.payBaseURL + "/" + inv.Token
+}
+
+// Get returns one of the merchant's invoices.
+func (s *Service) Get(ctx context.Context, merchantID, id uuid.UUID) (*domain.MerchantInvoice, error) {
+	inv, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if inv.MerchantID != merchantID {
+		return nil, ErrNotFound
+	}
+	return inv, nil
+}
+
+// List returns the merchant's invoices, newest first, optionally only those
+// with status.
+func (s *Service) List(ctx context.Context, merchantID uuid.UUID, status domain.MerchantInvoiceStatus, limit, offset int) ([]domain.MerchantInvoice, int, error) {
+	return s.repo.List(ctx, merchantID, status, limit, offset)
+}
+
+// Send makes a draft invoice payable and tells the payer it was addressed
+// to, if any.
+func (s *Service) Send(ctx context.Context, merchantID, id uuid.UUID) (*domain.MerchantInvoice, error) {
+	inv, err := s.Get(ctx, merchantID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	if inv.Status != domain.MerchantInvoiceDraft {
+		return nil, fmt.Errorf("%w: only draft invoices can be sent", ErrInvalidStatus)
+	}
+	if !now.Before(inv.ExpiresAt) {
+		return nil, fmt.Errorf("%w: the invoice has expired", ErrInvalidStatus)
+	}
+	inv.Status = domain.MerchantInvoiceSent
+	inv.SentAt = &now
+	inv.UpdatedAt = now
+	if err := s.transition(ctx, inv, domain.MerchantInvoiceDraft); err != nil {
+		return nil, err
+	}
+	merchant, err := s.users.FindByID(ctx, merchantID)
+	if err != nil {
+		s.logger.Warn("Failed to load merchant to notify invoice payer", map[string]interface{}{"error": err.Error(), "invoice_id": inv.ID})
+	} else {
+		s.notifyPayer(ctx, inv, merchant)
+	}
+	return inv, nil
+}
+
+// Cancel withdraws a draft or sent invoice.
+func (s *Service) Cancel(ctx context.Context, merchantID, id uuid.UUID) (*domain.MerchantInvoice, error) {
+	inv, err := s.Get(ctx, merchantID, id)
+	if err != nil {
+		return nil, err
+	}
+	from := inv.Status
+	if from != domain.MerchantInvoiceDraft && from != domain.MerchantInvoiceSent {
+		return nil, fmt.Errorf("%w: a %s invoice cannot be cancelled", ErrInvalidStatus, from)
+	}
+	now := s.now().UTC()
+	inv.Status = domain.MerchantInvoiceCancelled
+	inv.CancelledAt = &now
+	inv.UpdatedAt = now
+	if err := s.transition(ctx, inv, from); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (s *Service) transition(ctx context.Context, inv *domain.MerchantInvoice, from domain.MerchantInvoiceStatus) error {
+	ok, err := s.repo.Transition(ctx, inv, from)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: it changed in the meantime", ErrInvalidStatus)
+	}
+	return nil
+}
+
+// Payable is an invoice as its payer sees it.
+type Payable struct {
+	Invoice      *domain.MerchantInvoice
+	MerchantName string
+}
+
+// View returns the invoice behind a payment link to someone about to pay
+// it. Drafts, and invoices addressed to someone else, are not found.
+func (s *Service) View(ctx context.Context, payerID uuid.UUID, token string) (*Payable, error) {
+	inv, err := s.byToken(ctx, payerID, token)
+	if err != nil {
+		return nil, err
+	}
+	merchant, err := s.users.FindByID(ctx, inv.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	return &Payable{Invoice: inv, MerchantName: merchantName(merchant)}, nil
+}
+
+func (s *Service) byToken(ctx context.Context, payerID uuid.UUID, token string) (*domain.MerchantInvoice, error) {
+	inv, err := s.repo.GetByToken(ctx, strings.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+	if inv.Status == domain.MerchantInvoiceDraft || (inv.PayerID != nil && *inv.PayerID != payerID) {
+		return nil, ErrNotFound
+	}
+	return inv, nil
+}
+
+// Pay pays the invoice behind a payment link from the payer's wallet in its
+// currency. Paying an invoice the payer already paid returns it unchanged.
+func (s *Service) Pay(ctx context.Context, payerID uuid.UUID, token string, proof *StepUp) (*domain.MerchantInvoice, error) {
+	inv, err := s.byToken(ctx, payerID, token)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Status == domain.MerchantInvoicePaid && inv.PaidBy != nil && *inv.PaidBy == payerID {
+		return inv, nil
+	}
+	if inv.MerchantID == payerID {
+		return nil, fmt.Errorf("%w: you cannot pay your own invoice", ErrNotPayable)
+	}
+	if !inv.IsPayable(s.now()) {
+		if inv.Status == domain.MerchantInvoiceSent {
+			return nil, fmt.Errorf("%w: it has expired", ErrNotPayable)
+		}
+		return nil, fmt.Errorf("%w: it is %s", ErrNotPayable, inv.Status)
+	}
+
+	description := inv.Memo
+	if description == "" {
+		description = "Invoice " + inv.Reference
+	}
+	tx, err := s.payer.Pay(ctx, &Payment{
+		PayerID:     payerID,
+		MerchantID:  inv.MerchantID,
+		Amount:      inv.Amount,
+		Currency:    inv.Currency,
+		Reference:   "INV-" + inv.ID.String(),
+		Description: strings.TrimSpace(description),
+		Metadata:    domain.Metadata{"invoice_id": inv.ID.String()},
+		StepUp:      proof,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tx.SenderID != payerID {
+		// Someone else paid it a moment ago; the reference returned their
+		// payment.
+		return nil, fmt.Errorf("%w: it is paid", ErrNotPayable)
+	}
+	switch tx.Status {
+	case domain.TransactionStatusFailed, domain.TransactionStatusCancelled, domain.TransactionStatusReversed:
+		// The reference returned an earlier attempt that did not go
+		// through; the invoice stays unpaid.
+		return nil, fmt.Errorf("%w: your payment %s did not go through", ErrNotPayable, tx.ID)
+	}
+
+	now := s.now().UTC()
+	inv.Status = domain.MerchantInvoicePaid
+	inv.TransactionID = &tx.ID
+	inv.PaidBy = &payerID
+	inv.PaidAt = &now
+	inv.UpdatedAt = now
+	ok, err := s.repo.Transition(ctx, inv, domain.MerchantInvoiceSent)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Cancelled or expired while the payment was being made. The money
+		// has moved, so this needs a person to sort out.
+		s.logger.Error("Invoice paid after it was closed", map[string]interface{}{
+			"invoice_id":     inv.ID,
+			"transaction_id": tx.ID,
+		})
+		return nil, fmt.Errorf("%w: it was closed while you paid; your payment %s will be looked into", ErrNotPayable, tx.ID)
+	}
+	if err := s.notifier.Notify(ctx, inv.MerchantID, EventInvoicePaid, map[string]interface{}{
+		"invoice_id": inv.ID.String(),
+		"reference":  inv.Reference,
+		"amount":     inv.Amount.String(),
+		"currency":   string(inv.Currency),
+	}); err != nil {
+		s.logger.Warn("Failed to notify invoice payment", map[string]interface{}{"error": err.Error(), "invoice_id": inv.ID})
+	}
+	return inv, nil
+}
+
+func (s *Service) notifyPayer(ctx context.Context, inv *domain.MerchantInvoice, merchant *domain.User) {
+	if inv.PayerID == nil {
+		return
+	}
+	if err := s.notifier.Notify(ctx, *inv.PayerID, EventInvoiceReceived, map[string]interface{}{
+		"invoice_id":   inv.ID.String(),
+		"merchant":     merchantName(merchant),
+		"amount":       inv.Amount.String(),
+		"currency":     string(inv.Currency),
+		"payment_link": s.PaymentLink(inv),
+	}); err != nil {
+		s.logger.Warn("Failed to notify invoice payer", map[string]interface{}{"error": err.Error(), "invoice_id": inv.ID})
+	}
+}
+
+func merchantName(u *domain.User) string {
+	if u.BusinessName != nil && strings.TrimSpace(*u.BusinessName) != "" {
+		return strings.TrimSpace(*u.BusinessName)
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Start closes sent invoices past their expiry every interval until Stop.
+func (s *Service) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.ExpireDue(context.Background())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Invoice expiry worker started", map[string]interface{}{"interval": s.interval.String()})
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// ExpireDue closes every sent invoice past its expiry, reporting how many.
+func (s *Service) ExpireDue(ctx context.Context) int {
+	total := 0
+	for {
+		expired, err := s.repo.ExpireDue(ctx, s.now().UTC(), expiryBatchSize)
+		if err != nil {
+			s.logger.Error("Failed to expire invoices", map[string]interface{}{"error": err.Error()})
+			return total
+		}
+		total += len(expired)
+		if len(expired) < expiryBatchSize {
+			if total > 0 {
+				s.logger.Info("Invoices expired", map[string]interface{}{"count": total})
+			}
+			return total
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, inv *domain.MerchantInvoice) error {
+	return m.Called(ctx, inv).Error(0)
+}
+
+func (m *MockRepository) Get(ctx context.Context, id uuid.UUID) (*domain.MerchantInvoice, error) {
+	args := m.Called(ctx, id)
+	return copied(args.Get(0)), args.Error(1)
+}
+
+func (m *MockRepository) GetByToken(ctx context.Context, token string) (*domain.MerchantInvoice, error) {
+	args := m.Called(ctx, token)
+	return copied(args.Get(0)), args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, merchantID uuid.UUID, status domain.MerchantInvoiceStatus, limit, offset int) ([]domain.MerchantInvoice, int, error) {
+	args := m.Called(ctx, merchantID, status, limit, offset)
+	return args.Get(0).([]domain.MerchantInvoice), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) Transition(ctx context.Context, inv *domain.MerchantInvoice, from domain.MerchantInvoiceStatus) (bool, error) {
+	args := m.Called(ctx, inv, from)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ExpireDue(ctx context.Context, now time.Time, limit int) ([]domain.MerchantInvoice, error) {
+	args := m.Called(ctx, now, limit)
+	expired, _ := args.Get(0).([]domain.MerchantInvoice)
+	return expired, args.Error(1)
+}
+
+// copied returns a copy of a stored invoice, so the service cannot change
+// what the test set up.
+func copied(v interface{}) *domain.MerchantInvoice {
+	inv, _ := v.(*domain.MerchantInvoice)
+	if inv == nil {
+		return nil
+	}
+	cp := *inv
+	return &cp
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	u, _ := args.Get(0).(*domain.User)
+	return u, args.Error(1)
+}
+
+type MockWallets struct {
+	mock.Mock
+}
+
+func (m *MockWallets) FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error) {
+	args := m.Called(ctx, userID, currency)
+	w, _ := args.Get(0).(*domain.Wallet)
+	return w, args.Error(1)
+}
+
+type MockPayer struct {
+	mock.Mock
+}
+
+func (m *MockPayer) Pay(ctx context.Context, p *Payment) (*domain.Transaction, error) {
+	args := m.Called(ctx, p)
+	tx, _ := args.Get(0).(*domain.Transaction)
+	return tx, args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
+	return m.Called(ctx, userID, eventType, data).Error(0)
+}
+
+type mocks struct {
+	repo     *MockRepository
+	users    *MockUsers
+	wallets  *MockWallets
+	payer    *MockPayer
+	notifier *MockNotifier
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.users.AssertExpectations(t)
+	m.wallets.AssertExpectations(t)
+	m.payer.AssertExpectations(t)
+	m.notifier.AssertExpectations(t)
+}
+
+var (
+	ctx          = context.Background()
+	start        = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	businessName = "Chikondi Stores"
+	merchant     = &domain.User{ID: uuid.New(), UserType: domain.UserTypeMerchant, IsActive: true, BusinessName: &businessName}
+	customer     = &domain.User{ID: uuid.New(), UserType: domain.UserTypeIndividual, IsActive: true, FirstName: "Tiwonge", LastName: "Banda"}
+	wallet       = &domain.Wallet{ID: uuid.New(), UserID: merchant.ID, Currency: domain.MWK}
+)
+
+func newService(now *time.Time) (*Service, *mocks) {
+	m := &mocks{new(MockRepository), new(MockUsers), new(MockWallets), new(MockPayer), new(MockNotifier)}
+	s := NewService(m.repo, m.users, m.wallets, m.payer, m.notifier,
+		config.InvoiceConfig{PayBaseURL: "https://pay.example.com/i/", DefaultExpiry: 24 * time.Hour, MaxExpiry: 30 * 24 * time.Hour},
+		logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, m
+}
+
+// issued is a merchant's invoice in status, expiring a day after start.
+func issued(status domain.MerchantInvoiceStatus) *domain.MerchantInvoice {
+	return &domain.MerchantInvoice{
+		ID:         uuid.New(),
+		MerchantID: merchant.ID,
+		WalletID:   wallet.ID,
+		Reference:  "7",
+		Amount:     decimal.NewFromInt(25000),
+		Currency:   domain.MWK,
+		Memo:       "Order 7",
+		Status:     status,
+		Token:      uuid.NewString(),
+		ExpiresAt:  start.Add(24 * time.Hour),
+	}
+}
+
+func TestCreate(t *testing.T) {
+	now := start
+	s, m := newService(&now)
+	m.users.On("FindByID", ctx, merchant.ID).Return(merchant, nil).Once()
+	m.wallets.On("FindByUserAndCurrency", ctx, merchant.ID, domain.MWK).Return(wallet, nil).Once()
+	m.repo.On("Create", ctx, mock.MatchedBy(func(inv *domain.MerchantInvoice) bool {
+		return inv.WalletID == wallet.ID && inv.Reference == "INV-118"
+	})).Return(nil).Once()
+
+	inv, err := s.Create(ctx, &CreateRequest{MerchantID: merchant.ID, Amount: decimal.NewFromInt(25000), Currency: domain.MWK, Memo: " Order 118 ", Reference: " INV-118 "})
+	require.NoError(t, err)
+	assert.Equal(t, domain.MerchantInvoiceDraft, inv.Status)
+	assert.Equal(t, "Order 118", inv.Memo)
+	assert.Equal(t, now.Add(24*time.Hour), inv.ExpiresAt, "the default expiry")
+	assert.Len(t, inv.Token, 32)
+	assert.Equal(t, "https://pay.example.com/i/"+inv.Token, s.PaymentLink(inv))
+	m.assert(t)
+	m.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	t.Run("reference taken", func(t *testing.T) {
+		s, m := newService(&now)
+		m.users.On("FindByID", ctx, merchant.ID).Return(merchant, nil).Once()
+		m.wallets.On("FindByUserAndCurrency", ctx, merchant.ID, domain.MWK).Return(wallet, nil).Once()
+		m.repo.On("Create", ctx, mock.Anything).Return(ErrReferenceExists).Once()
+		_, err := s.Create(ctx, &CreateRequest{MerchantID: merchant.ID, Amount: decimal.NewFromInt(1), Currency: domain.MWK, Reference: "INV-118"})
+		assert.ErrorIs(t, err, ErrReferenceExists)
+	})
+
+	t.Run("not a merchant", func(t *testing.T) {
+		s, m := newService(&now)
+		m.users.On("FindByID", ctx, customer.ID).Return(customer, nil).Once()
+		_, err := s.Create(ctx, &CreateRequest{MerchantID: customer.ID, Amount: decimal.NewFromInt(1), Currency: domain.MWK})
+		assert.ErrorIs(t, err, ErrNotMerchant)
+		m.repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCreateValidates(t *testing.T) {
+	now := start
+	tooLate := now.Add(31 * 24 * time.Hour)
+	unknown := uuid.New()
+	tests := []struct {
+		name string
+		req  CreateRequest
+	}{
+		{"zero amount", CreateRequest{Amount: decimal.Zero, Currency: domain.MWK}},
+		{"fractional tambala", CreateRequest{Amount: decimal.RequireFromString("10.005"), Currency: domain.MWK}},
+		{"bad currency", CreateRequest{Amount: decimal.NewFromInt(1), Currency: "mwk"}},
+		{"no wallet", CreateRequest{Amount: decimal.NewFromInt(1), Currency: domain.ZAR}},
+		{"past max expiry", CreateRequest{Amount: decimal.NewFromInt(1), Currency: domain.MWK, ExpiresAt: &tooLate}},
+		{"unknown payer", CreateRequest{Amount: decimal.NewFromInt(1), Currency: domain.MWK, PayerID: &unknown}},
+		{"self", CreateRequest{Amount: decimal.NewFromInt(1), Currency: domain.MWK, PayerID: &merchant.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newService(&now)
+			m.users.On("FindByID", ctx, merchant.ID).Return(merchant, nil)
+			m.users.On("FindByID", ctx, unknown).Return(nil, pkgerrors.ErrUserNotFound).Maybe()
+			m.wallets.On("FindByUserAndCurrency", ctx, merchant.ID, domain.MWK).Return(wallet, nil).Maybe()
+			m.wallets.On("FindByUserAndCurrency", ctx, merchant.ID, domain.ZAR).Return(nil, nil).Maybe()
+
+			req := tt.req
+			req.MerchantID = merchant.ID
+			_, err := s.Create(ctx, &req)
+			assert.ErrorIs(t, err, ErrInvalidInvoice)
+			m.repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateAndSend(t *testing.T) {
+	now := start
+	s, m := newService(&now)
+	m.users.On("FindByID", ctx, merchant.ID).Return(merchant, nil).Once()
+	m.users.On("FindByID", ctx, customer.ID).Return(customer, nil).Once()
+	m.wallets.On("FindByUserAndCurrency", ctx, merchant.ID, domain.MWK).Return(wallet, nil).Once()
+	m.repo.On("Create", ctx, mock.MatchedBy(func(inv *domain.MerchantInvoice) bool {
+		return inv.Status == domain.MerchantInvoiceSent && inv.SentAt.Equal(now)
+	})).Return(nil).Once()
+	m.notifier.On("Notify", ctx, customer.ID, EventInvoiceReceived, mock.MatchedBy(func(data map[string]interface{}) bool {
+		return data["merchant"] == "Chikondi Stores" && data["amount"] == "25000"
+	})).Return(nil).Once()
+
+	inv, err := s.Create(ctx, &CreateRequest{MerchantID: merchant.ID, Amount: decimal.NewFromInt(25000), Currency: domain.MWK, PayerID: &customer.ID, Send: true})
+	require.NoError(t, err)
+	assert.Equal(t, domain.MerchantInvoiceSent, inv.Status)
+	m.assert(t)
+}
+
+func TestSend(t *testing.T) {
+	now := start
+	draft := issued(domain.MerchantInvoiceDraft)
+	draft.PayerID = &customer.ID
+	s, m := newService(&now)
+	m.repo.On("Get", ctx, draft.ID).Return(draft, nil)
+	m.repo.On("Transition", ctx, mock.MatchedBy(func(inv *domain.MerchantInvoice) bool {
+		return inv.Status == domain.MerchantInvoiceSent && inv.SentAt.Equal(now)
+	}), domain.MerchantInvoiceDraft).Return(true, nil).Once()
+	m.users.On("FindByID", ctx, merchant.ID).Return(merchant, nil).Once()
+	m.notifier.On("Notify", ctx, customer.ID, EventInvoiceReceived, mock.Anything).Return(nil).Once()
+
+	_, err := s.Send(ctx, customer.ID, draft.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "another user's invoice")
+
+	sent, err := s.Send(ctx, merchant.ID, draft.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.MerchantInvoiceSent, sent.Status)
+	m.assert(t)
+
+	t.Run("already sent", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("Get", ctx, sent.ID).Return(sent, nil).Once()
+		_, err := s.Send(ctx, merchant.ID, sent.ID)
+		assert.ErrorIs(t, err, ErrInvalidStatus)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		later := now.Add(24 * time.Hour)
+		s, m := newService(&later)
+		m.repo.On("Get", ctx, draft.ID).Return(draft, nil).Once()
+		_, err := s.Send(ctx, merchant.ID, draft.ID)
+		assert.ErrorIs(t, err, ErrInvalidStatus)
+		m.repo.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("changed in the meantime", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("Get", ctx, draft.ID).Return(draft, nil).Once()
+		m.repo.On("Transition", ctx, mock.Anything, domain.MerchantInvoiceDraft).Return(false, nil).Once()
+		_, err := s.Send(ctx, merchant.ID, draft.ID)
+		assert.ErrorIs(t, err, ErrInvalidStatus)
+		m.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCancel(t *testing.T) {
+	now := start
+	for _, from := range []domain.MerchantInvoiceStatus{domain.MerchantInvoiceDraft, domain.MerchantInvoiceSent} {
+		t.Run(string(from), func(t *testing.T) {
+			inv := issued(from)
+			s, m := newService(&now)
+			m.repo.On("Get", ctx, inv.ID).Return(inv, nil).Once()
+			m.repo.On("Transition", ctx, mock.MatchedBy(func(inv *domain.MerchantInvoice) bool {
+				return inv.Status == domain.MerchantInvoiceCancelled && inv.CancelledAt.Equal(now)
+			}), from).Return(true, nil).Once()
+
+			cancelled, err := s.Cancel(ctx, merchant.ID, inv.ID)
+			require.NoError(t, err)
+			assert.Equal(t, domain.MerchantInvoiceCancelled, cancelled.Status)
+			m.assert(t)
+		})
+	}
+
+	for _, closed := range []domain.MerchantInvoiceStatus{domain.MerchantInvoicePaid, domain.MerchantInvoiceExpired, domain.MerchantInvoiceCancelled} {
+		t.Run(string(closed), func(t *testing.T) {
+			inv := issued(closed)
+			s, m := newService(&now)
+			m.repo.On("Get", ctx, inv.ID).Return(inv, nil).Once()
+			_, err := s.Cancel(ctx, merchant.ID, inv.ID)
+			assert.ErrorIs(t, err, ErrInvalidStatus)
+			m.repo.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	now := start
+	draft := issued(domain.MerchantInvoiceDraft)
+	sent := issued(domain.MerchantInvoiceSent)
+	addressed := issued(domain.MerchantInvoiceSent)
+	addressed.PayerID = &customer.ID
+	s, m := newService(&now)
+	m.repo.On("GetByToken", ctx, draft.Token).Return(draft, nil)
+	m.repo.On("GetByToken", ctx, sent.Token).Return(sent, nil)
+	m.repo.On("GetByToken", ctx, addressed.Token).Return(addressed, nil)
+	m.users.On("FindByID", ctx, merchant.ID).Return(merchant, nil)
+
+	_, err := s.View(ctx, customer.ID, draft.Token)
+	assert.ErrorIs(t, err, ErrNotFound, "drafts have no working link")
+	_, err = s.View(ctx, uuid.New(), addressed.Token)
+	assert.ErrorIs(t, err, ErrNotFound, "only the addressed payer can see it")
+
+	view, err := s.View(ctx, customer.ID, " "+sent.Token+" ")
+	require.NoError(t, err)
+	assert.Equal(t, sent.ID, view.Invoice.ID)
+	assert.Equal(t, "Chikondi Stores", view.MerchantName)
+}
+
+func TestPay(t *testing.T) {
+	now := start
+	inv := issued(domain.MerchantInvoiceSent)
+	tx := &domain.Transaction{ID: uuid.New(), SenderID: customer.ID, Status: domain.TransactionStatusCompleted}
+	s, m := newService(&now)
+	m.repo.On("GetByToken", ctx, inv.Token).Return(inv, nil).Once()
+	m.payer.On("Pay", ctx, mock.MatchedBy(func(p *Payment) bool {
+		return p.PayerID == customer.ID && p.MerchantID == merchant.ID && p.Amount.Equal(inv.Amount) &&
+			p.Reference == "INV-"+inv.ID.String() && p.Description == "Order 7" && p.Metadata["invoice_id"] == inv.ID.String()
+	})).Return(tx, nil).Once()
+	m.repo.On("Transition", ctx, mock.MatchedBy(func(paid *domain.MerchantInvoice) bool {
+		return paid.Status == domain.MerchantInvoicePaid && *paid.TransactionID == tx.ID && *paid.PaidBy == customer.ID && paid.PaidAt.Equal(now)
+	}), domain.MerchantInvoiceSent).Return(true, nil).Once()
+	m.notifier.On("Notify", ctx, merchant.ID, EventInvoicePaid, mock.Anything).Return(nil).Once()
+
+	paid, err := s.Pay(ctx, customer.ID, inv.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, domain.MerchantInvoicePaid, paid.Status)
+	assert.Equal(t, tx.ID, *paid.TransactionID)
+	m.assert(t)
+
+	t.Run("paying again returns the paid invoice", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetByToken", ctx, paid.Token).Return(paid, nil).Once()
+		again, err := s.Pay(ctx, customer.ID, paid.Token, nil)
+		require.NoError(t, err)
+		assert.Equal(t, tx.ID, *again.TransactionID)
+		m.payer.AssertNotCalled(t, "Pay", mock.Anything, mock.Anything)
+	})
+
+	t.Run("paid by someone else", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetByToken", ctx, paid.Token).Return(paid, nil).Once()
+		_, err := s.Pay(ctx, uuid.New(), paid.Token, nil)
+		assert.ErrorIs(t, err, ErrNotPayable)
+		m.payer.AssertNotCalled(t, "Pay", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPayRefuses(t *testing.T) {
+	now := start
+	payer := uuid.New()
+	tests := []struct {
+		name    string
+		payer   uuid.UUID
+		now     time.Time
+		tx      *domain.Transaction
+		payErr  error
+		wantErr error
+	}{
+		{name: "the merchant's own invoice", payer: merchant.ID, wantErr: ErrNotPayable},
+		{name: "past its expiry", payer: payer, now: now.Add(25 * time.Hour), wantErr: ErrNotPayable},
+		{name: "payment errors are passed on", payer: payer, payErr: errors.New("insufficient balance")},
+		{name: "an earlier attempt that failed", payer: payer, tx: &domain.Transaction{ID: uuid.New(), SenderID: payer, Status: domain.TransactionStatusFailed}, wantErr: ErrNotPayable},
+		{name: "someone else paid a moment ago", payer: payer, tx: &domain.Transaction{ID: uuid.New(), SenderID: uuid.New()}, wantErr: ErrNotPayable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := now
+			if !tt.now.IsZero() {
+				clock = tt.now
+			}
+			inv := issued(domain.MerchantInvoiceSent)
+			s, m := newService(&clock)
+			m.repo.On("GetByToken", ctx, inv.Token).Return(inv, nil).Once()
+			if tt.tx != nil || tt.payErr != nil {
+				m.payer.On("Pay", ctx, mock.Anything).Return(tt.tx, tt.payErr).Once()
+			}
+
+			_, err := s.Pay(ctx, tt.payer, inv.Token, nil)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.Equal(t, tt.payErr, err)
+			}
+			m.assert(t)
+			m.repo.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("closed while paying", func(t *testing.T) {
+		inv := issued(domain.MerchantInvoiceSent)
+		s, m := newService(&now)
+		m.repo.On("GetByToken", ctx, inv.Token).Return(inv, nil).Once()
+		m.payer.On("Pay", ctx, mock.Anything).Return(&domain.Transaction{ID: uuid.New(), SenderID: payer}, nil).Once()
+		m.repo.On("Transition", ctx, mock.Anything, domain.MerchantInvoiceSent).Return(false, nil).Once()
+
+		_, err := s.Pay(ctx, payer, inv.Token, nil)
+		assert.ErrorIs(t, err, ErrNotPayable)
+		m.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestExpireDue(t *testing.T) {
+	now := start
+	full := make([]domain.MerchantInvoice, expiryBatchSize)
+	s, m := newService(&now)
+	m.repo.On("ExpireDue", ctx, now, expiryBatchSize).Return(full, nil).Once()
+	m.repo.On("ExpireDue", ctx, now, expiryBatchSize).Return(make([]domain.MerchantInvoice, 2), nil).Once()
+	assert.Equal(t, expiryBatchSize+2, s.ExpireDue(ctx), "until a batch is not full")
+	m.assert(t)
+
+	s, m = newService(&now)
+	m.repo.On("ExpireDue", ctx, now, expiryBatchSize).Return(full, nil).Once()
+	m.repo.On("ExpireDue", ctx, now, expiryBatchSize).Return(nil, errors.New("connection refused")).Once()
+	assert.Equal(t, expiryBatchSize, s.ExpireDue(ctx), "those expired before the failure")
+	m.assert(t)
+}
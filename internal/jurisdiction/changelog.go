@@ -0,0 +1,90 @@
+package jurisdiction
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"time"
+
+	"kyd/internal/domain"
+)
+
+// changeLogPage is how many change log entries are read at a time for an
+// export.
+const changeLogPage = 500
+
+var changeLogHeader = []string{
+	"changed_at", "country_code", "action", "jurisdiction_id",
+	"scope_before", "scope_after", "effective_from_before", "effective_from_after",
+	"effective_until_before", "effective_until_after", "reason", "authority",
+	"note", "changed_by",
+}
+
+// ChangeLogCSV exports every change log entry matching f, oldest first, as
+// CSV for regulator review. Each row shows the restriction's scope and
+// dates before and after the change, so the list in force on any day can
+// be rebuilt from the export.
+func (s *Service) ChangeLogCSV(ctx context.Context, f domain.JurisdictionChangeFilter) ([]byte, error) {
+	var all []domain.JurisdictionChange
+	for offset := 0; ; offset += changeLogPage {
+		page, total, err := s.Changes(ctx, f, changeLogPage, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < changeLogPage || len(all) >= total {
+			break
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeChangeLog(&buf, all); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeChangeLog writes changes, given newest first, oldest first.
+func writeChangeLog(w io.Writer, changes []domain.JurisdictionChange) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(changeLogHeader); err != nil {
+		return err
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		var scopeBefore, scopeAfter, fromBefore, fromAfter, untilBefore, untilAfter string
+		// Reason and authority are as they stood after the change, or as
+		// they were for a removal.
+		current := c.After
+		if b := c.Before; b != nil {
+			scopeBefore, fromBefore, untilBefore = string(b.Scope), formatTime(&b.EffectiveFrom), formatTime(b.EffectiveUntil)
+			if current == nil {
+				current = b
+			}
+		}
+		if a := c.After; a != nil {
+			scopeAfter, fromAfter, untilAfter = string(a.Scope), formatTime(&a.EffectiveFrom), formatTime(a.EffectiveUntil)
+		}
+		var reason, authority string
+		if current != nil {
+			reason, authority = current.Reason, current.Authority
+		}
+		if err := cw.Write([]string{
+			formatTime(&c.ChangedAt), c.CountryCode, string(c.Action), c.JurisdictionID.String(),
+			scopeBefore, scopeAfter, fromBefore, fromAfter,
+			untilBefore, untilAfter, reason, authority,
+			c.Note, c.ChangedBy.String(),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
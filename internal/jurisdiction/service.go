@@ -0,0 +1,357 @@
+// Package jurisdiction keeps the restricted jurisdictions list: the
+// countries money may not come from, go to, or both, each restriction in
+// force between its effective dates. Embargoes are usually announced ahead
+// of the date they apply from, so restrictions can be scheduled and their
+// end set in advance. Every addition, change and removal is kept in an
+// append-only change log for regulator review.
+//
+// Payment initiation and settlement submission check the restrictions in
+// force, which each process caches briefly.
+package jurisdiction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound = errors.New("restricted jurisdiction not found")
+	ErrInvalid  = errors.New("invalid restricted jurisdiction")
+	ErrOverlap  = errors.New("the country is already restricted in that direction for part of that period")
+	ErrInEffect = errors.New("restriction has already taken effect")
+	ErrConflict = errors.New("restriction was changed by another admin")
+)
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+type Repository interface {
+	// Create saves j and records c in one transaction.
+	Create(ctx context.Context, j *domain.RestrictedJurisdiction, c *domain.JurisdictionChange) error
+	// Update saves j and records c unless j changed since prev, reporting
+	// false when another admin changed it first; Delete likewise.
+	Update(ctx context.Context, j *domain.RestrictedJurisdiction, prev time.Time, c *domain.JurisdictionChange) (bool, error)
+	Delete(ctx context.Context, id uuid.UUID, prev time.Time, c *domain.JurisdictionChange) (bool, error)
+	// Get returns nil when there is no such restriction.
+	Get(ctx context.Context, id uuid.UUID) (*domain.RestrictedJurisdiction, error)
+	// List returns the restrictions, of one country when it is non-empty,
+	// by country and effective date.
+	List(ctx context.Context, country string) ([]domain.RestrictedJurisdiction, error)
+	// Current returns the restrictions not ended at now: in force or
+	// scheduled.
+	Current(ctx context.Context, now time.Time) ([]domain.RestrictedJurisdiction, error)
+	Changes(ctx context.Context, f domain.JurisdictionChangeFilter, limit, offset int) ([]domain.JurisdictionChange, int, error)
+}
+
+// Request adds or changes a restriction. Note says why the change is made
+// and is kept in the change log; it is required for changes and removals.
+type Request struct {
+	CountryCode    string                   `json:"country_code"`
+	Scope          domain.JurisdictionScope `json:"scope"`
+	Reason         string                   `json:"reason"`
+	Authority      string                   `json:"authority,omitempty"`
+	EffectiveFrom  *time.Time               `json:"effective_from,omitempty"` // now when left out
+	EffectiveUntil *time.Time               `json:"effective_until,omitempty"`
+	Note           string                   `json:"note,omitempty"`
+}
+
+// Restriction is a restriction with its status at the time it was read.
+type Restriction struct {
+	domain.RestrictedJurisdiction
+	Status string `json:"status"`
+}
+
+type Service struct {
+	repo   Repository
+	cfg    config.JurisdictionConfig
+	logger logger.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	current   []domain.RestrictedJurisdiction
+	fetchedAt time.Time
+}
+
+func NewService(repo Repository, cfg config.JurisdictionConfig, log logger.Logger) *Service {
+	return &Service{repo: repo, cfg: cfg, logger: log, now: time.Now}
+}
+
+// Create adds a restriction, in force from its effective date.
+func (s *Service) Create(ctx context.Context, req Request, adminID uuid.UUID) (*domain.RestrictedJurisdiction, error) {
+	now := s.now().UTC()
+	j := &domain.RestrictedJurisdiction{ID: uuid.New(), CreatedBy: adminID, CreatedAt: now}
+	if err := s.apply(j, req, now); err != nil {
+		return nil, err
+	}
+	if req.EffectiveFrom != nil && req.EffectiveFrom.Before(now) {
+		return nil, fmt.Errorf("%w: effective_from cannot be in the past", ErrInvalid)
+	}
+	if err := s.checkOverlap(ctx, j); err != nil {
+		return nil, err
+	}
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		note = j.Reason
+	}
+	if err := s.repo.Create(ctx, j, s.change(j.ID, j.CountryCode, domain.JurisdictionCreated, nil, j, note, adminID, now)); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return j, nil
+}
+
+// Update changes a restriction. Once one has taken effect its country,
+// scope and start stay as they were enforced, and it can only be ended, no
+// earlier than now; a change of scope is a new restriction.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, req Request, adminID uuid.UUID) (*domain.RestrictedJurisdiction, error) {
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		return nil, fmt.Errorf("%w: note is required", ErrInvalid)
+	}
+	before, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	j := *before
+	if err := s.apply(&j, req, now); err != nil {
+		return nil, err
+	}
+	if !now.Before(before.EffectiveFrom) {
+		if j.CountryCode != before.CountryCode || j.Scope != before.Scope || !j.EffectiveFrom.Equal(before.EffectiveFrom) {
+			return nil, fmt.Errorf("%w: only its end, reason and authority can change", ErrInEffect)
+		}
+		if before.EffectiveUntil != nil && !now.Before(*before.EffectiveUntil) {
+			return nil, fmt.Errorf("%w: it has ended", ErrInEffect)
+		}
+		if j.EffectiveUntil != nil && j.EffectiveUntil.Before(now) {
+			return nil, fmt.Errorf("%w: effective_until cannot be in the past", ErrInvalid)
+		}
+	} else if j.EffectiveFrom.Before(now) {
+		return nil, fmt.Errorf("%w: effective_from cannot be in the past", ErrInvalid)
+	}
+	if err := s.checkOverlap(ctx, &j); err != nil {
+		return nil, err
+	}
+	j.UpdatedBy, j.UpdatedAt = adminID, now
+	ok, err := s.repo.Update(ctx, &j, before.UpdatedAt, s.change(j.ID, j.CountryCode, domain.JurisdictionUpdated, before, &j, note, adminID, now))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrConflict
+	}
+	s.invalidate()
+	return &j, nil
+}
+
+// Delete removes a scheduled restriction. One that has taken effect was
+// enforced and stays on the list; it is ended instead.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID, note string, adminID uuid.UUID) error {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return fmt.Errorf("%w: note is required", ErrInvalid)
+	}
+	j, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	now := s.now().UTC()
+	if !now.Before(j.EffectiveFrom) {
+		return fmt.Errorf("%w: set effective_until to end it", ErrInEffect)
+	}
+	ok, err := s.repo.Delete(ctx, id, j.UpdatedAt, s.change(j.ID, j.CountryCode, domain.JurisdictionDeleted, j, nil, note, adminID, now))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConflict
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*Restriction, error) {
+	j, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &Restriction{RestrictedJurisdiction: *j, Status: j.Status(s.now())}, nil
+}
+
+// List returns the restrictions, of one country when it is non-empty, and
+// with one status ("scheduled", "in_force" or "ended") when that is.
+func (s *Service) List(ctx context.Context, country, status string) ([]Restriction, error) {
+	all, err := s.repo.List(ctx, strings.ToUpper(strings.TrimSpace(country)))
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	out := make([]Restriction, 0, len(all))
+	for _, j := range all {
+		st := j.Status(now)
+		if status != "" && st != status {
+			continue
+		}
+		out = append(out, Restriction{RestrictedJurisdiction: j, Status: st})
+	}
+	return out, nil
+}
+
+// Changes returns the change log, newest first.
+func (s *Service) Changes(ctx context.Context, f domain.JurisdictionChangeFilter, limit, offset int) ([]domain.JurisdictionChange, int, error) {
+	f.CountryCode = strings.ToUpper(strings.TrimSpace(f.CountryCode))
+	return s.repo.Changes(ctx, f, limit, offset)
+}
+
+// Check refuses money moving out of a country restricted inbound, or into
+// one restricted outbound, with pkgerrors.ErrJurisdictionRestricted.
+func (s *Service) Check(ctx context.Context, f domain.JurisdictionFlow) error {
+	now := s.now()
+	for _, j := range s.Current(ctx) {
+		if !j.InForce(now) {
+			continue
+		}
+		if j.Stops(domain.JurisdictionInbound) && contains(f.Origins, j.CountryCode) {
+			return fmt.Errorf("%w: payments from %s are not permitted", pkgerrors.ErrJurisdictionRestricted, j.CountryCode)
+		}
+		if j.Stops(domain.JurisdictionOutbound) && contains(f.Destinations, j.CountryCode) {
+			return fmt.Errorf("%w: payments to %s are not permitted", pkgerrors.ErrJurisdictionRestricted, j.CountryCode)
+		}
+	}
+	return nil
+}
+
+// Current returns the restrictions in force or scheduled, as of at most
+// CacheTTL ago; Check applies each on its own dates. When they cannot be
+// loaded the last known restrictions stay in force.
+func (s *Service) Current(ctx context.Context) []domain.RestrictedJurisdiction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if !s.fetchedAt.IsZero() && now.Sub(s.fetchedAt) < s.cfg.CacheTTL {
+		return s.current
+	}
+	current, err := s.repo.Current(ctx, now)
+	if err != nil {
+		s.logger.Error("Failed to load restricted jurisdictions", map[string]interface{}{"error": err.Error()})
+		return s.current
+	}
+	s.current, s.fetchedAt = current, now
+	return current
+}
+
+// apply validates req onto j.
+func (s *Service) apply(j *domain.RestrictedJurisdiction, req Request, now time.Time) error {
+	country := strings.ToUpper(strings.TrimSpace(req.CountryCode))
+	if !countryPattern.MatchString(country) {
+		return fmt.Errorf("%w: country_code must be an ISO 3166 alpha-2 code, such as IR", ErrInvalid)
+	}
+	switch req.Scope {
+	case domain.JurisdictionInbound, domain.JurisdictionOutbound, domain.JurisdictionBoth:
+	default:
+		return fmt.Errorf("%w: scope must be inbound, outbound or both", ErrInvalid)
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return fmt.Errorf("%w: reason is required", ErrInvalid)
+	}
+	from := now
+	if req.EffectiveFrom != nil {
+		from = req.EffectiveFrom.UTC()
+	} else if !j.EffectiveFrom.IsZero() {
+		from = j.EffectiveFrom
+	}
+	var until *time.Time
+	if req.EffectiveUntil != nil {
+		u := req.EffectiveUntil.UTC()
+		if !u.After(from) {
+			return fmt.Errorf("%w: effective_until must be after effective_from", ErrInvalid)
+		}
+		until = &u
+	}
+	j.CountryCode, j.Scope, j.Reason, j.Authority = country, req.Scope, reason, strings.TrimSpace(req.Authority)
+	j.EffectiveFrom, j.EffectiveUntil = from, until
+	if j.UpdatedAt.IsZero() {
+		j.UpdatedBy, j.UpdatedAt = j.CreatedBy, now
+	}
+	return nil
+}
+
+// checkOverlap refuses a restriction that would stop the same money as
+// another one of its country at the same time.
+func (s *Service) checkOverlap(ctx context.Context, j *domain.RestrictedJurisdiction) error {
+	others, err := s.repo.List(ctx, j.CountryCode)
+	if err != nil {
+		return err
+	}
+	for _, o := range others {
+		if o.ID == j.ID {
+			continue
+		}
+		sharesScope := o.Scope == j.Scope || o.Scope == domain.JurisdictionBoth || j.Scope == domain.JurisdictionBoth
+		if sharesScope && overlaps(&o, j) {
+			return ErrOverlap
+		}
+	}
+	return nil
+}
+
+func overlaps(a, b *domain.RestrictedJurisdiction) bool {
+	aEndsAfterB := a.EffectiveUntil == nil || a.EffectiveUntil.After(b.EffectiveFrom)
+	bEndsAfterA := b.EffectiveUntil == nil || b.EffectiveUntil.After(a.EffectiveFrom)
+	return aEndsAfterB && bEndsAfterA
+}
+
+func (s *Service) get(ctx context.Context, id uuid.UUID) (*domain.RestrictedJurisdiction, error) {
+	j, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		return nil, ErrNotFound
+	}
+	return j, nil
+}
+
+func (s *Service) change(id uuid.UUID, country string, action domain.JurisdictionAction, before, after *domain.RestrictedJurisdiction, note string, adminID uuid.UUID, at time.Time) *domain.JurisdictionChange {
+	return &domain.JurisdictionChange{
+		ID:             uuid.New(),
+		JurisdictionID: id,
+		CountryCode:    country,
+		Action:         action,
+		Before:         before,
+		After:          after,
+		Note:           note,
+		ChangedBy:      adminID,
+		ChangedAt:      at,
+	}
+}
+
+// invalidate makes the next check reload, so that a change takes effect at
+// once in this process.
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.fetchedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+func contains(countries []string, country string) bool {
+	for _, c := range countries {
+		if strings.ToUpper(strings.TrimSpace(c)) == country {
+			return true
+		}
+	}
+	return false
+}
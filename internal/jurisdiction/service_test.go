@@ -0,0 +1,349 @@
+package jurisdiction
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, j *domain.RestrictedJurisdiction, c *domain.JurisdictionChange) error {
+	return m.Called(ctx, j, c).Error(0)
+}
+
+func (m *MockRepository) Update(ctx context.Context, j *domain.RestrictedJurisdiction, prev time.Time, c *domain.JurisdictionChange) (bool, error) {
+	args := m.Called(ctx, j, prev, c)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, id uuid.UUID, prev time.Time, c *domain.JurisdictionChange) (bool, error) {
+	args := m.Called(ctx, id, prev, c)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Get(ctx context.Context, id uuid.UUID) (*domain.RestrictedJurisdiction, error) {
+	args := m.Called(ctx, id)
+	j, _ := args.Get(0).(*domain.RestrictedJurisdiction)
+	if j != nil {
+		cp := *j
+		j = &cp
+	}
+	return j, args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, country string) ([]domain.RestrictedJurisdiction, error) {
+	args := m.Called(ctx, country)
+	items, _ := args.Get(0).([]domain.RestrictedJurisdiction)
+	return items, args.Error(1)
+}
+
+func (m *MockRepository) Current(ctx context.Context, now time.Time) ([]domain.RestrictedJurisdiction, error) {
+	args := m.Called(ctx, now)
+	items, _ := args.Get(0).([]domain.RestrictedJurisdiction)
+	return items, args.Error(1)
+}
+
+func (m *MockRepository) Changes(ctx context.Context, f domain.JurisdictionChangeFilter, limit, offset int) ([]domain.JurisdictionChange, int, error) {
+	args := m.Called(ctx, f, limit, offset)
+	changes, _ := args.Get(0).([]domain.JurisdictionChange)
+	return changes, args.Int(1), args.Error(2)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	admin = uuid.New()
+)
+
+func newService(now *time.Time) (*Service, *MockRepository) {
+	repo := new(MockRepository)
+	s := NewService(repo, config.JurisdictionConfig{CacheTTL: 5 * time.Second}, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo
+}
+
+func at(t time.Time) *time.Time { return &t }
+
+// restriction is a saved restriction of country in scope from from, until
+// until when that is set.
+func restriction(country string, scope domain.JurisdictionScope, from time.Time, until *time.Time) *domain.RestrictedJurisdiction {
+	created := from.Add(-time.Hour)
+	return &domain.RestrictedJurisdiction{
+		ID: uuid.New(), CountryCode: country, Scope: scope, Reason: "sanctions",
+		EffectiveFrom: from, EffectiveUntil: until,
+		CreatedBy: admin, UpdatedBy: admin, CreatedAt: created, UpdatedAt: created,
+	}
+}
+
+var (
+	fromIran = domain.JurisdictionFlow{Origins: []string{"IR"}, Destinations: []string{"MW"}}
+	toIran   = domain.JurisdictionFlow{Origins: []string{"MW"}, Destinations: []string{"IR"}}
+)
+
+func TestCreateValidation(t *testing.T) {
+	now := start
+	for _, req := range []Request{
+		{CountryCode: "IRN", Scope: domain.JurisdictionBoth, Reason: "sanctions"},
+		{CountryCode: "IR", Scope: "sideways", Reason: "sanctions"},
+		{CountryCode: "IR", Scope: domain.JurisdictionBoth, Reason: " "},
+		{CountryCode: "IR", Scope: domain.JurisdictionBoth, Reason: "sanctions", EffectiveFrom: at(now.Add(-time.Hour))},
+		{CountryCode: "IR", Scope: domain.JurisdictionBoth, Reason: "sanctions", EffectiveUntil: at(now)},
+	} {
+		s, repo := newService(&now)
+		_, err := s.Create(ctx, req, admin)
+		assert.ErrorIs(t, err, ErrInvalid, "%+v", req)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	}
+
+	s, repo := newService(&now)
+	repo.On("List", ctx, "IR").Return(nil, nil).Once()
+	repo.On("Create", ctx, mock.MatchedBy(func(j *domain.RestrictedJurisdiction) bool {
+		return j.CountryCode == "IR" && j.Scope == domain.JurisdictionInbound && j.Authority == "UNSC 1737" &&
+			j.EffectiveFrom.Equal(now) && j.EffectiveUntil == nil && j.CreatedBy == admin && j.UpdatedBy == admin
+	}), mock.MatchedBy(func(c *domain.JurisdictionChange) bool {
+		return c.Action == domain.JurisdictionCreated && c.CountryCode == "IR" && c.Before == nil && c.After != nil &&
+			c.Note == "sanctions" && c.ChangedBy == admin && c.ChangedAt.Equal(now)
+	})).Return(nil).Once()
+	j, err := s.Create(ctx, Request{CountryCode: " ir ", Scope: domain.JurisdictionInbound, Reason: "sanctions", Authority: "UNSC 1737"}, admin)
+	require.NoError(t, err)
+	assert.Equal(t, now, j.EffectiveFrom, "in force at once by default")
+	repo.AssertExpectations(t)
+
+	t.Run("overlaps", func(t *testing.T) {
+		inbound := restriction("IR", domain.JurisdictionInbound, now, nil)
+		s, repo := newService(&now)
+		repo.On("List", ctx, "IR").Return([]domain.RestrictedJurisdiction{*inbound}, nil)
+
+		_, err := s.Create(ctx, Request{CountryCode: "IR", Scope: domain.JurisdictionBoth, Reason: "again", EffectiveFrom: at(now.Add(24 * time.Hour))}, admin)
+		assert.ErrorIs(t, err, ErrOverlap)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+
+		repo.On("Create", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+		_, err = s.Create(ctx, Request{CountryCode: "IR", Scope: domain.JurisdictionOutbound, Reason: "outbound too"}, admin)
+		assert.NoError(t, err, "the other direction is a separate restriction")
+	})
+}
+
+func TestCheckFollowsEffectiveDates(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	begin, end := now.Add(time.Second), now.Add(48*time.Hour)
+	embargo := restriction("IR", domain.JurisdictionOutbound, begin, &end)
+	repo.On("Current", ctx, now).Return([]domain.RestrictedJurisdiction{*embargo}, nil).Once()
+
+	assert.NoError(t, s.Check(ctx, toIran), "scheduled")
+	now = begin
+	err := s.Check(ctx, toIran)
+	assert.ErrorIs(t, err, pkgerrors.ErrJurisdictionRestricted, "in force once its date comes, without reloading")
+	assert.Contains(t, err.Error(), "payments to IR are not permitted")
+	assert.NoError(t, s.Check(ctx, fromIran), "outbound only")
+	repo.AssertExpectations(t)
+
+	now = end
+	repo.On("Current", ctx, now).Return([]domain.RestrictedJurisdiction{*embargo}, nil).Once()
+	assert.NoError(t, s.Check(ctx, toIran), "ended")
+	repo.AssertExpectations(t)
+}
+
+func TestCheckKeepsLastKnownList(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	kp := restriction("KP", domain.JurisdictionBoth, now.Add(-time.Hour), nil)
+	repo.On("Current", ctx, now).Return([]domain.RestrictedJurisdiction{*kp}, nil).Once()
+	require.Error(t, s.Check(ctx, domain.JurisdictionFlow{Origins: []string{"kp"}}))
+
+	now = now.Add(time.Minute)
+	repo.On("Current", ctx, now).Return(nil, errors.New("database unavailable")).Once()
+	assert.ErrorIs(t, s.Check(ctx, domain.JurisdictionFlow{Destinations: []string{"KP"}}), pkgerrors.ErrJurisdictionRestricted)
+	repo.AssertExpectations(t)
+}
+
+func TestWritesApplyAtOnce(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	repo.On("Current", ctx, now).Return(nil, nil).Once()
+	require.NoError(t, s.Check(ctx, toIran))
+
+	repo.On("List", ctx, "IR").Return(nil, nil).Once()
+	repo.On("Create", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+	j, err := s.Create(ctx, Request{CountryCode: "IR", Scope: domain.JurisdictionBoth, Reason: "sanctions"}, admin)
+	require.NoError(t, err)
+
+	repo.On("Current", ctx, now).Return([]domain.RestrictedJurisdiction{*j}, nil).Once()
+	assert.ErrorIs(t, s.Check(ctx, toIran), pkgerrors.ErrJurisdictionRestricted, "within the cache TTL")
+	repo.AssertExpectations(t)
+}
+
+func TestUpdate(t *testing.T) {
+	now := start
+	update := func(scope domain.JurisdictionScope, until *time.Time, note string) Request {
+		return Request{CountryCode: "SY", Scope: scope, Reason: "sanctions", EffectiveUntil: until, Note: note}
+	}
+
+	t.Run("a note is required", func(t *testing.T) {
+		s, repo := newService(&now)
+		_, err := s.Update(ctx, uuid.New(), update(domain.JurisdictionInbound, nil, " "), admin)
+		assert.ErrorIs(t, err, ErrInvalid)
+		repo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s, repo := newService(&now)
+		id := uuid.New()
+		repo.On("Get", ctx, id).Return(nil, nil).Once()
+		_, err := s.Update(ctx, id, update(domain.JurisdictionInbound, nil, "tidy"), admin)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("a scheduled restriction can change freely", func(t *testing.T) {
+		s, repo := newService(&now)
+		scheduled := restriction("SY", domain.JurisdictionBoth, now.Add(24*time.Hour), nil)
+		repo.On("Get", ctx, scheduled.ID).Return(scheduled, nil).Once()
+		repo.On("List", ctx, "SY").Return([]domain.RestrictedJurisdiction{*scheduled}, nil).Once()
+		repo.On("Update", ctx, mock.MatchedBy(func(j *domain.RestrictedJurisdiction) bool {
+			return j.ID == scheduled.ID && j.Scope == domain.JurisdictionInbound && j.UpdatedAt.Equal(now)
+		}), scheduled.UpdatedAt, mock.MatchedBy(func(c *domain.JurisdictionChange) bool {
+			return c.Action == domain.JurisdictionUpdated && c.Before.Scope == domain.JurisdictionBoth &&
+				c.After.Scope == domain.JurisdictionInbound && c.Note == "inbound only per directive"
+		})).Return(true, nil).Once()
+
+		j, err := s.Update(ctx, scheduled.ID, update(domain.JurisdictionInbound, nil, "inbound only per directive"), admin)
+		require.NoError(t, err)
+		assert.Equal(t, scheduled.EffectiveFrom, j.EffectiveFrom, "kept when left out")
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("another admin changed it first", func(t *testing.T) {
+		s, repo := newService(&now)
+		scheduled := restriction("CU", domain.JurisdictionBoth, now.Add(time.Hour), nil)
+		repo.On("Get", ctx, scheduled.ID).Return(scheduled, nil).Once()
+		repo.On("List", ctx, "CU").Return(nil, nil).Once()
+		repo.On("Update", ctx, mock.Anything, scheduled.UpdatedAt, mock.Anything).Return(false, nil).Once()
+		_, err := s.Update(ctx, scheduled.ID, Request{CountryCode: "CU", Scope: domain.JurisdictionBoth, Reason: "sanctions", Note: "tidy"}, admin)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("one in force can only be ended", func(t *testing.T) {
+		inForce := restriction("SY", domain.JurisdictionInbound, now.Add(-time.Hour), nil)
+		for name, req := range map[string]Request{
+			"widened":   update(domain.JurisdictionBoth, nil, "widen"),
+			"moved":     {CountryCode: "SY", Scope: domain.JurisdictionInbound, Reason: "sanctions", EffectiveFrom: at(now.Add(time.Hour)), Note: "later"},
+			"backdated": update(domain.JurisdictionInbound, at(now.Add(-time.Minute)), "backdated"),
+		} {
+			s, repo := newService(&now)
+			repo.On("Get", ctx, inForce.ID).Return(inForce, nil).Once()
+			_, err := s.Update(ctx, inForce.ID, req, admin)
+			assert.Error(t, err, name)
+			repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		}
+
+		s, repo := newService(&now)
+		end := now.Add(time.Hour)
+		repo.On("Get", ctx, inForce.ID).Return(inForce, nil).Once()
+		repo.On("List", ctx, "SY").Return([]domain.RestrictedJurisdiction{*inForce}, nil).Once()
+		repo.On("Update", ctx, mock.MatchedBy(func(j *domain.RestrictedJurisdiction) bool {
+			return j.EffectiveUntil != nil && j.EffectiveUntil.Equal(end)
+		}), inForce.UpdatedAt, mock.MatchedBy(func(c *domain.JurisdictionChange) bool {
+			return c.Before.EffectiveUntil == nil
+		})).Return(true, nil).Once()
+		_, err := s.Update(ctx, inForce.ID, update(domain.JurisdictionInbound, &end, "sanctions lifted"), admin)
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("an ended restriction stays as it was", func(t *testing.T) {
+		s, repo := newService(&now)
+		ended := restriction("SY", domain.JurisdictionInbound, now.Add(-2*time.Hour), at(now.Add(-time.Hour)))
+		repo.On("Get", ctx, ended.ID).Return(ended, nil).Once()
+		_, err := s.Update(ctx, ended.ID, update(domain.JurisdictionInbound, nil, "reopen"), admin)
+		assert.ErrorIs(t, err, ErrInEffect)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	now := start
+	scheduled := restriction("SY", domain.JurisdictionBoth, now.Add(time.Hour), nil)
+	inForce := restriction("SY", domain.JurisdictionInbound, now.Add(-time.Hour), nil)
+
+	s, repo := newService(&now)
+	assert.ErrorIs(t, s.Delete(ctx, scheduled.ID, "", admin), ErrInvalid)
+	repo.On("Get", ctx, inForce.ID).Return(inForce, nil).Once()
+	assert.ErrorIs(t, s.Delete(ctx, inForce.ID, "mistake", admin), ErrInEffect)
+
+	repo.On("Get", ctx, scheduled.ID).Return(scheduled, nil).Twice()
+	repo.On("Delete", ctx, scheduled.ID, scheduled.UpdatedAt, mock.MatchedBy(func(c *domain.JurisdictionChange) bool {
+		return c.Action == domain.JurisdictionDeleted && c.Before != nil && c.After == nil && c.Note == "announced in error"
+	})).Return(true, nil).Once()
+	repo.On("Delete", ctx, scheduled.ID, scheduled.UpdatedAt, mock.Anything).Return(false, nil).Once()
+	require.NoError(t, s.Delete(ctx, scheduled.ID, "announced in error", admin))
+	assert.ErrorIs(t, s.Delete(ctx, scheduled.ID, "again", admin), ErrConflict)
+	repo.AssertExpectations(t)
+}
+
+func TestList(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	ended := restriction("SY", domain.JurisdictionInbound, now.Add(-2*time.Hour), at(now.Add(-time.Hour)))
+	inForce := restriction("SY", domain.JurisdictionOutbound, now.Add(-time.Hour), nil)
+	repo.On("List", ctx, "SY").Return([]domain.RestrictedJurisdiction{*ended, *inForce}, nil).Twice()
+
+	all, err := s.List(ctx, " sy", "")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "ended", all[0].Status)
+	assert.Equal(t, "in_force", all[1].Status)
+
+	list, err := s.List(ctx, "SY", "ended")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, ended.ID, list[0].ID)
+	repo.AssertExpectations(t)
+}
+
+func TestChangeLogCSV(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	created := restriction("IR", domain.JurisdictionBoth, now, nil)
+	created.Authority = "UNSC 1737"
+	ended := *created
+	ended.EffectiveUntil = at(now.Add(2 * time.Hour))
+	changes := []domain.JurisdictionChange{
+		{ID: uuid.New(), JurisdictionID: created.ID, CountryCode: "IR", Action: domain.JurisdictionUpdated, Before: created, After: &ended, Note: "lifted", ChangedBy: admin, ChangedAt: now.Add(time.Hour)},
+		{ID: uuid.New(), JurisdictionID: created.ID, CountryCode: "IR", Action: domain.JurisdictionCreated, After: created, Note: "sanctions", ChangedBy: admin, ChangedAt: now},
+	}
+	repo.On("Changes", ctx, domain.JurisdictionChangeFilter{CountryCode: "IR"}, changeLogPage, 0).Return(changes, 2, nil).Once()
+
+	content, err := s.ChangeLogCSV(ctx, domain.JurisdictionChangeFilter{CountryCode: "ir"})
+	require.NoError(t, err)
+	rows, err := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, changeLogHeader, rows[0])
+	assert.Equal(t, []string{
+		"2026-03-01T09:00:00Z", "IR", "created", created.ID.String(),
+		"", "both", "", "2026-03-01T09:00:00Z",
+		"", "", "sanctions", "UNSC 1737",
+		"sanctions", admin.String(),
+	}, rows[1], "oldest first")
+	assert.Equal(t, "updated", rows[2][2])
+	assert.Equal(t, "", rows[2][8])
+	assert.Equal(t, "2026-03-01T11:00:00Z", rows[2][9])
+	assert.Equal(t, "lifted", rows[2][12])
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,116 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DesignateHouseUser books userID's wallets under account from now on,
+// instead of as money owed to customers; the fee collector's wallets are
+// fee income. The wallets' current balances are moved across with a
+// reclassification journal in the same transaction. It reports false when
+// the user already was a house user.
+func (s *Service) DesignateHouseUser(ctx context.Context, userID uuid.UUID, account string) (bool, error) {
+	if _, ok := domain.LookupLedgerAccount(account); !ok || account == domain.AccountCustomerWallets {
+		return false, fmt.Errorf("account %q cannot hold house wallets", account)
+	}
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_house_users (user_id, account_code)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID, account)
+	if err != nil {
+		return false, errors.Wrap(err, "designate house user failed")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, currency, ledger_balance FROM customer_schema.wallets
+		WHERE user_id = $1
+		ORDER BY id
+		FOR UPDATE
+	`, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "house wallet lock failed")
+	}
+	j := newJournal(nil, "house_reclassification")
+	for rows.Next() {
+		var walletID uuid.UUID
+		var currency domain.Currency
+		var balance decimal.Decimal
+		if err := rows.Scan(&walletID, &currency, &balance); err != nil {
+			rows.Close()
+			return false, errors.Wrap(err, "house wallet lock failed")
+		}
+		id := walletID
+		j.debit(domain.AccountCustomerWallets, currency, balance, &id)
+		j.credit(account, currency, balance, &id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, errors.Wrap(err, "house wallet lock failed")
+	}
+	if len(j.Lines) > 0 {
+		if err := s.writeJournals(ctx, tx, j); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	return true, nil
+}
+
+// TrialBalance lists every account's debits, credits and balance per
+// currency from the journals posted up to asOf.
+func (s *Service) TrialBalance(ctx context.Context, asOf time.Time) (*domain.TrialBalance, error) {
+	lines, err := s.ledgerRepo.TrialBalance(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return newTrialBalance(asOf, lines), nil
+}
+
+func newTrialBalance(asOf time.Time, lines []domain.TrialBalanceLine) *domain.TrialBalance {
+	tb := &domain.TrialBalance{AsOf: asOf, Lines: lines, Totals: []domain.TrialBalanceTotal{}, Balanced: true}
+	totals := make(map[domain.Currency]int)
+	for i := range tb.Lines {
+		l := &tb.Lines[i]
+		l.Balance = l.Credit.Sub(l.Debit)
+		if l.AccountType.DebitNormal() {
+			l.Balance = l.Balance.Neg()
+		}
+		k, ok := totals[l.Currency]
+		if !ok {
+			k = len(tb.Totals)
+			totals[l.Currency] = k
+			tb.Totals = append(tb.Totals, domain.TrialBalanceTotal{Currency: l.Currency})
+		}
+		tb.Totals[k].Debit = tb.Totals[k].Debit.Add(l.Debit)
+		tb.Totals[k].Credit = tb.Totals[k].Credit.Add(l.Credit)
+	}
+	sort.Slice(tb.Totals, func(i, j int) bool { return tb.Totals[i].Currency < tb.Totals[j].Currency })
+	for i := range tb.Totals {
+		tb.Totals[i].Balanced = tb.Totals[i].Debit.Equal(tb.Totals[i].Credit)
+		tb.Balanced = tb.Balanced && tb.Totals[i].Balanced
+	}
+	return tb
+}
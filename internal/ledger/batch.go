@@ -0,0 +1,488 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/repository/postgres"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidBatch is returned when a journal batch fails validation; nothing
+// in the batch is posted.
+var ErrInvalidBatch = errors.New("invalid ledger batch")
+
+// maxBatchSize bounds one journal batch so its transaction stays short
+// enough not to starve single postings of the wallet locks it holds.
+const maxBatchSize = 10000
+
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// BatchReceipt reports the outcome of PostBatch. Receipts are in the order
+// of the submitted postings.
+type BatchReceipt struct {
+	Receipts []*PostingReceipt `json:"receipts"`
+	Entries  int               `json:"entries"`
+	Replayed int               `json:"replayed"`
+}
+
+// ledgerEntry is a row of customer_schema.ledger_entries.
+type ledgerEntry struct {
+	ID            uuid.UUID
+	TransactionID uuid.UUID
+	WalletID      uuid.UUID
+	EntryType     string
+	Amount        decimal.Decimal
+	Currency      domain.Currency
+	BalanceAfter  decimal.Decimal
+	CreatedAt     time.Time
+	PreviousHash  string
+	Hash          string
+}
+
+// journal is a batch applied in memory against locked wallet balances.
+type journal struct {
+	entries  []ledgerEntry
+	events   []postgres.LedgerEvent
+	deltas   map[uuid.UUID]decimal.Decimal
+	credited map[uuid.UUID]bool
+	receipts []*PostingReceipt
+}
+
+// PostBatch posts a journal batch atomically: either every posting is applied
+// or none is. Wallets are locked once, balances and per-wallet hash chains are
+// advanced in memory in submission order, and the entries are written with a
+// single COPY. Postings whose IdempotencyKey was already posted are replayed
+// rather than applied again.
+func (s *Service) PostBatch(ctx context.Context, postings []*LedgerPosting) (*BatchReceipt, error) {
+	if err := validateBatch(postings); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		receipt, err := s.postBatch(ctx, postings)
+		if err != nil && attempt < maxPostAttempts && isSerializationFailure(err) {
+			continue
+		}
+		return receipt, err
+	}
+}
+
+func validateBatch(postings []*LedgerPosting) error {
+	if len(postings) == 0 || len(postings) > maxBatchSize {
+		return fmt.Errorf("%w: batch must hold 1 to %d postings", ErrInvalidBatch, maxBatchSize)
+	}
+	keys := make(map[string]int)
+	for i, p := range postings {
+		var problem string
+		switch {
+		case p == nil:
+			problem = "missing posting"
+		case p.TransactionID == uuid.Nil:
+			problem = "missing transaction id"
+		case p.DebitWalletID == uuid.Nil || p.CreditWalletID == uuid.Nil:
+			problem = "missing wallet"
+		case p.DebitWalletID == p.CreditWalletID:
+			problem = "debit and credit wallet are the same"
+		case !p.DebitAmount.IsPositive() || !p.CreditAmount.IsPositive():
+			problem = "amounts must be positive"
+		case p.FeeAmount.IsNegative():
+			problem = "fee must not be negative"
+		case p.Currency == "" || p.ConvertedCurrency == "":
+			problem = "missing currency"
+		}
+		if problem == "" && p.IdempotencyKey != "" {
+			if j, ok := keys[p.IdempotencyKey]; ok {
+				problem = fmt.Sprintf("idempotency key repeats posting %d", j)
+			}
+			keys[p.IdempotencyKey] = i
+		}
+		if problem != "" {
+			return fmt.Errorf("%w: posting %d: %s", ErrInvalidBatch, i, problem)
+		}
+	}
+	return nil
+}
+
+func (s *Service) postBatch(ctx context.Context, postings []*LedgerPosting) (*BatchReceipt, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	replayed, err := s.claimBatchKeys(ctx, tx, postings)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*LedgerPosting, 0, len(postings))
+	for i, p := range postings {
+		if replayed[i] == nil {
+			pending = append(pending, p)
+		}
+	}
+
+	result := &BatchReceipt{Receipts: make([]*PostingReceipt, len(postings)), Replayed: len(postings) - len(pending)}
+	if len(pending) > 0 {
+		balances, heads, err := s.lockBatchWallets(ctx, tx, pending)
+		if err != nil {
+			return nil, err
+		}
+		j, err := s.buildJournal(pending, balances, heads, time.Now().UTC().Truncate(time.Microsecond))
+		if err != nil {
+			return nil, err
+		}
+		if err := s.writeJournal(ctx, tx, j); err != nil {
+			return nil, err
+		}
+		if err := s.writeBatchJournals(ctx, tx, pending, balances); err != nil {
+			return nil, err
+		}
+		result.Entries = len(j.entries)
+
+		next := 0
+		for i := range postings {
+			if replayed[i] == nil {
+				result.Receipts[i] = j.receipts[next]
+				next++
+			}
+		}
+	}
+	for i, r := range replayed {
+		if r != nil {
+			result.Receipts[i] = r
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "transaction commit failed")
+	}
+	return result, nil
+}
+
+// claimBatchKeys claims the idempotency keys of a batch in one statement. It
+// returns the original receipts of postings whose key was posted before,
+// indexed like postings.
+func (s *Service) claimBatchKeys(ctx context.Context, tx *sqlx.Tx, postings []*LedgerPosting) ([]*PostingReceipt, error) {
+	replayed := make([]*PostingReceipt, len(postings))
+	var keys, txIDs, prints []string
+	byKey := make(map[string]int)
+	for i, p := range postings {
+		if p.IdempotencyKey == "" {
+			continue
+		}
+		keys = append(keys, p.IdempotencyKey)
+		txIDs = append(txIDs, p.TransactionID.String())
+		prints = append(prints, p.fingerprint())
+		byKey[p.IdempotencyKey] = i
+	}
+	if len(keys) == 0 {
+		return replayed, nil
+	}
+
+	var claimed []string
+	err := tx.SelectContext(ctx, &claimed, `
+		INSERT INTO customer_schema.ledger_postings (idempotency_key, transaction_id, fingerprint, created_at)
+		SELECT k, t::uuid, f, NOW()
+		FROM unnest($1::text[], $2::text[], $3::text[]) AS v(k, t, f)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING idempotency_key
+	`, pq.Array(keys), pq.Array(txIDs), pq.Array(prints))
+	if err != nil {
+		return nil, errors.Wrap(err, "claim idempotency keys failed")
+	}
+	if len(claimed) == len(keys) {
+		return replayed, nil
+	}
+	isClaimed := make(map[string]bool, len(claimed))
+	for _, k := range claimed {
+		isClaimed[k] = true
+	}
+	var existing []string
+	for _, k := range keys {
+		if !isClaimed[k] {
+			existing = append(existing, k)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT idempotency_key, transaction_id, fingerprint, debit_entry_id, credit_entry_id, fee_entry_id, posted_at
+		FROM customer_schema.ledger_postings
+		WHERE idempotency_key = ANY($1)
+	`, pq.Array(existing))
+	if err != nil {
+		return nil, errors.Wrap(err, "load idempotent postings failed")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			r             PostingReceipt
+			fingerprint   string
+			debit, credit uuid.NullUUID
+			fee           uuid.NullUUID
+			postedAt      sql.NullTime
+		)
+		if err := rows.Scan(&r.IdempotencyKey, &r.TransactionID, &fingerprint, &debit, &credit, &fee, &postedAt); err != nil {
+			return nil, errors.Wrap(err, "scan idempotent posting failed")
+		}
+		i := byKey[r.IdempotencyKey]
+		if fingerprint != postings[i].fingerprint() {
+			return nil, fmt.Errorf("%w: posting %d", ErrIdempotencyConflict, i)
+		}
+		r.DebitEntryID = debit.UUID
+		r.CreditEntryID = credit.UUID
+		if fee.Valid {
+			r.FeeEntryID = &fee.UUID
+		}
+		r.PostedAt = postedAt.Time
+		r.Replayed = true
+		replayed[i] = &r
+	}
+	return replayed, errors.Wrap(rows.Err(), "load idempotent postings failed")
+}
+
+// lockBatchWallets locks every wallet the batch touches, in id order like
+// single postings, and returns their balances and current chain heads.
+func (s *Service) lockBatchWallets(ctx context.Context, tx *sqlx.Tx, postings []*LedgerPosting) (map[uuid.UUID]decimal.Decimal, map[uuid.UUID]string, error) {
+	seen := make(map[uuid.UUID]bool)
+	var ids []string
+	add := func(id uuid.UUID) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id.String())
+		}
+	}
+	for _, p := range postings {
+		add(p.DebitWalletID)
+		add(p.CreditWalletID)
+		if p.FeeWalletID != nil && p.FeeAmount.IsPositive() {
+			add(*p.FeeWalletID)
+		}
+	}
+	sort.Strings(ids)
+
+	balances := make(map[uuid.UUID]decimal.Decimal, len(ids))
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, available_balance FROM customer_schema.wallets
+		WHERE id = ANY($1::uuid[])
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "wallet lock failed")
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var balance decimal.Decimal
+		if err := rows.Scan(&id, &balance); err != nil {
+			rows.Close()
+			return nil, nil, errors.Wrap(err, "wallet lock failed")
+		}
+		balances[id] = balance
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "wallet lock failed")
+	}
+	if len(balances) != len(ids) {
+		return nil, nil, errors.ErrWalletNotFound
+	}
+
+	heads := make(map[uuid.UUID]string, len(ids))
+	rows, err = tx.QueryContext(ctx, `
+		SELECT DISTINCT ON (wallet_id) wallet_id, hash
+		FROM customer_schema.ledger_entries
+		WHERE wallet_id = ANY($1::uuid[])
+		ORDER BY wallet_id, created_at DESC, id DESC
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get previous hashes")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		var hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get previous hashes")
+		}
+		heads[id] = hash
+	}
+	return balances, heads, errors.Wrap(rows.Err(), "failed to get previous hashes")
+}
+
+// buildJournal applies postings in order to the locked balances, chaining
+// each wallet's entries from its current head. Entries get strictly
+// increasing timestamps from base so the (created_at, id) order used to walk
+// a chain matches the order they were hashed in.
+func (s *Service) buildJournal(postings []*LedgerPosting, balances map[uuid.UUID]decimal.Decimal, heads map[uuid.UUID]string, base time.Time) (*journal, error) {
+	j := &journal{
+		deltas:   make(map[uuid.UUID]decimal.Decimal),
+		credited: make(map[uuid.UUID]bool),
+	}
+	seq := 0
+	add := func(p *LedgerPosting, walletID uuid.UUID, entryType string, amount decimal.Decimal, currency domain.Currency) uuid.UUID {
+		if entryType == "debit" {
+			balances[walletID] = balances[walletID].Sub(amount)
+			j.deltas[walletID] = j.deltas[walletID].Sub(amount)
+		} else {
+			balances[walletID] = balances[walletID].Add(amount)
+			j.deltas[walletID] = j.deltas[walletID].Add(amount)
+			j.credited[walletID] = true
+		}
+		prev, ok := heads[walletID]
+		if !ok {
+			prev = genesisHash
+		}
+		e := ledgerEntry{
+			ID:            uuid.New(),
+			TransactionID: p.TransactionID,
+			WalletID:      walletID,
+			EntryType:     entryType,
+			Amount:        amount,
+			Currency:      currency,
+			BalanceAfter:  balances[walletID],
+			CreatedAt:     base.Add(time.Duration(seq) * time.Microsecond),
+			PreviousHash:  prev,
+		}
+		seq++
+		e.Hash = s.calculateHash(prev, e.ID, e.TransactionID, walletID, entryType, amount, currency, e.BalanceAfter, e.CreatedAt)
+		heads[walletID] = e.Hash
+		j.entries = append(j.entries, e)
+		return e.ID
+	}
+
+	for i, p := range postings {
+		if balances[p.DebitWalletID].LessThan(p.DebitAmount) {
+			return nil, fmt.Errorf("posting %d: %w", i, errors.ErrInsufficientBalance)
+		}
+		r := &PostingReceipt{IdempotencyKey: p.IdempotencyKey, TransactionID: p.TransactionID}
+		r.DebitEntryID = add(p, p.DebitWalletID, "debit", p.DebitAmount, p.Currency)
+		r.CreditEntryID = add(p, p.CreditWalletID, "credit", p.CreditAmount, p.ConvertedCurrency)
+		r.PostedAt = base
+		if p.FeeWalletID != nil && p.FeeAmount.IsPositive() {
+			feeID := add(p, *p.FeeWalletID, "credit", p.FeeAmount, p.Currency)
+			r.FeeEntryID = &feeID
+			j.events = append(j.events, postgres.LedgerEvent{
+				TransactionID: p.TransactionID, EventType: "fee", Amount: p.FeeAmount, Currency: p.Currency, Status: "completed",
+			})
+		}
+		eventType := p.EventType
+		if eventType == "" {
+			eventType = "payment"
+		}
+		j.events = append(j.events, postgres.LedgerEvent{
+			TransactionID: p.TransactionID, EventType: eventType, Amount: p.DebitAmount, Currency: p.Currency, Status: "completed",
+		})
+		j.receipts = append(j.receipts, r)
+	}
+	return j, nil
+}
+
+// writeJournal applies the net balance change of each wallet with one
+// UPDATE, copies the entries in, appends the transaction ledger events and
+// records the receipts of keyed postings.
+func (s *Service) writeJournal(ctx context.Context, tx *sqlx.Tx, j *journal) error {
+	ids := make([]string, 0, len(j.deltas))
+	deltas := make([]string, 0, len(j.deltas))
+	credited := make([]bool, 0, len(j.deltas))
+	for id, d := range j.deltas {
+		ids = append(ids, id.String())
+		deltas = append(deltas, d.String())
+		credited = append(credited, j.credited[id])
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.wallets w
+		SET
+			available_balance = w.available_balance + v.delta,
+			ledger_balance = w.ledger_balance + v.delta,
+			last_transaction_at = CASE WHEN v.credited THEN NOW() ELSE w.last_transaction_at END,
+			updated_at = NOW()
+		FROM unnest($1::uuid[], $2::numeric[], $3::boolean[]) AS v(id, delta, credited)
+		WHERE w.id = v.id
+	`, pq.Array(ids), pq.Array(deltas), pq.Array(credited))
+	if err != nil {
+		return errors.Wrap(err, "batch wallet update failed")
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema("customer_schema", "ledger_entries",
+		"id", "transaction_id", "wallet_id", "entry_type", "amount", "currency",
+		"balance_after", "created_at", "previous_hash", "hash",
+	))
+	if err != nil {
+		return errors.Wrap(err, "prepare ledger copy failed")
+	}
+	defer stmt.Close()
+	for _, e := range j.entries {
+		if _, err := stmt.ExecContext(ctx, e.ID, e.TransactionID, e.WalletID, e.EntryType, e.Amount, string(e.Currency), e.BalanceAfter, e.CreatedAt, e.PreviousHash, e.Hash); err != nil {
+			return errors.Wrap(err, "copy ledger entry failed")
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "copy ledger entries failed")
+	}
+
+	if err := s.ledgerRepo.CreateEntriesTx(ctx, tx, j.events); err != nil {
+		return errors.Wrap(err, "failed to create immutable ledger entries")
+	}
+	return s.recordBatchReceipts(ctx, tx, j.receipts)
+}
+
+// writeBatchJournals writes the general ledger journal of each posting.
+func (s *Service) writeBatchJournals(ctx context.Context, tx *sqlx.Tx, postings []*LedgerPosting, locked map[uuid.UUID]decimal.Decimal) error {
+	walletIDs := make([]uuid.UUID, 0, len(locked))
+	for id := range locked {
+		walletIDs = append(walletIDs, id)
+	}
+	accounts, err := s.walletAccounts(ctx, tx, walletIDs...)
+	if err != nil {
+		return err
+	}
+	journals := make([]*Journal, len(postings))
+	for i, p := range postings {
+		journals[i] = postingJournal(p, accounts)
+		if err := journals[i].Validate(); err != nil {
+			return fmt.Errorf("posting %d: %w", i, err)
+		}
+	}
+	return s.writeJournals(ctx, tx, journals...)
+}
+
+func (s *Service) recordBatchReceipts(ctx context.Context, tx *sqlx.Tx, receipts []*PostingReceipt) error {
+	var keys, debits, credits, fees []string
+	var postedAt time.Time
+	for _, r := range receipts {
+		if r.IdempotencyKey == "" {
+			continue
+		}
+		keys = append(keys, r.IdempotencyKey)
+		debits = append(debits, r.DebitEntryID.String())
+		credits = append(credits, r.CreditEntryID.String())
+		fee := ""
+		if r.FeeEntryID != nil {
+			fee = r.FeeEntryID.String()
+		}
+		fees = append(fees, fee)
+		postedAt = r.PostedAt
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.ledger_postings p
+		SET debit_entry_id = v.debit::uuid,
+			credit_entry_id = v.credit::uuid,
+			fee_entry_id = NULLIF(v.fee, '')::uuid,
+			posted_at = $5
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[]) AS v(k, debit, credit, fee)
+		WHERE p.idempotency_key = v.k
+	`, pq.Array(keys), pq.Array(debits), pq.Array(credits), pq.Array(fees), postedAt)
+	return errors.Wrap(err, "record idempotent postings failed")
+}
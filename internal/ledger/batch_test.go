@@ -0,0 +1,105 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payrollPosting(from, to uuid.UUID, amount string) *LedgerPosting {
+	return &LedgerPosting{
+		TransactionID:     uuid.New(),
+		DebitWalletID:     from,
+		CreditWalletID:    to,
+		DebitAmount:       decimal.RequireFromString(amount),
+		CreditAmount:      decimal.RequireFromString(amount),
+		Currency:          "MWK",
+		ConvertedCurrency: "MWK",
+		EventType:         "payroll",
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	employer, a := uuid.New(), uuid.New()
+
+	assert.ErrorIs(t, validateBatch(nil), ErrInvalidBatch)
+
+	same := payrollPosting(employer, employer, "10")
+	assert.ErrorIs(t, validateBatch([]*LedgerPosting{same}), ErrInvalidBatch)
+
+	zero := payrollPosting(employer, a, "0")
+	assert.ErrorIs(t, validateBatch([]*LedgerPosting{zero}), ErrInvalidBatch)
+
+	p1, p2 := payrollPosting(employer, a, "10"), payrollPosting(employer, a, "20")
+	p1.IdempotencyKey, p2.IdempotencyKey = "payroll:1", "payroll:1"
+	err := validateBatch([]*LedgerPosting{p1, p2})
+	assert.ErrorIs(t, err, ErrInvalidBatch)
+	assert.Contains(t, err.Error(), "posting 1")
+
+	p2.IdempotencyKey = "payroll:2"
+	assert.NoError(t, validateBatch([]*LedgerPosting{p1, p2}))
+}
+
+func TestBuildJournal_ChainsPerWallet(t *testing.T) {
+	employer, a, b, fees := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	balances := map[uuid.UUID]decimal.Decimal{
+		employer: decimal.NewFromInt(1000),
+		a:        decimal.NewFromInt(5),
+		b:        decimal.Zero,
+		fees:     decimal.Zero,
+	}
+	heads := map[uuid.UUID]string{employer: "employer-head"}
+
+	withFee := payrollPosting(employer, b, "201")
+	withFee.CreditAmount = decimal.NewFromInt(200)
+	withFee.FeeWalletID = &fees
+	withFee.FeeAmount = decimal.NewFromInt(1)
+	postings := []*LedgerPosting{payrollPosting(employer, a, "300"), withFee}
+
+	base := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	s := &Service{}
+	j, err := s.buildJournal(postings, balances, heads, base)
+	require.NoError(t, err)
+	require.Len(t, j.entries, 5)
+	require.Len(t, j.receipts, 2)
+
+	// The employer's chain continues from its stored head, then links the
+	// second debit to the first.
+	first, second := j.entries[0], j.entries[2]
+	assert.Equal(t, employer, first.WalletID)
+	assert.Equal(t, "employer-head", first.PreviousHash)
+	assert.Equal(t, first.Hash, second.PreviousHash)
+	assert.True(t, decimal.NewFromInt(499).Equal(second.BalanceAfter))
+	assert.Equal(t, genesisHash, j.entries[1].PreviousHash)
+
+	for i := 1; i < len(j.entries); i++ {
+		assert.True(t, j.entries[i].CreatedAt.After(j.entries[i-1].CreatedAt))
+	}
+	for _, e := range j.entries {
+		assert.Equal(t, s.calculateHash(e.PreviousHash, e.ID, e.TransactionID, e.WalletID, e.EntryType, e.Amount, e.Currency, e.BalanceAfter, e.CreatedAt), e.Hash)
+	}
+
+	assert.True(t, decimal.NewFromInt(-501).Equal(j.deltas[employer]))
+	assert.True(t, decimal.NewFromInt(200).Equal(j.deltas[b]))
+	assert.True(t, j.credited[fees])
+	assert.False(t, j.credited[employer])
+	require.NotNil(t, j.receipts[1].FeeEntryID)
+	assert.Len(t, j.events, 3)
+	assert.Equal(t, "fee", j.events[1].EventType)
+}
+
+func TestBuildJournal_InsufficientBalance(t *testing.T) {
+	employer, a := uuid.New(), uuid.New()
+	balances := map[uuid.UUID]decimal.Decimal{employer: decimal.NewFromInt(100), a: decimal.Zero}
+	postings := []*LedgerPosting{payrollPosting(employer, a, "60"), payrollPosting(employer, a, "60")}
+
+	_, err := (&Service{}).buildJournal(postings, balances, map[uuid.UUID]string{}, time.Now())
+	assert.ErrorIs(t, err, errors.ErrInsufficientBalance)
+	assert.Contains(t, err.Error(), "posting 1")
+}
@@ -0,0 +1,175 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// AccrueCreditInterest books interest accrued on an overdrawn wallet as
+// receivable and adds it to the facility, which then has accrued through
+// a.PeriodEnd, in one database transaction. The wallet is not touched:
+// the interest is collected once the wallet is back in credit. It reports
+// false, writing nothing, when the period was already accrued.
+func (s *Service) AccrueCreditInterest(ctx context.Context, a *domain.CreditInterestAccrual) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	a.Amount = money(a.Amount)
+	j := newJournal(nil, "credit_interest_accrual")
+	j.debit(domain.AccountCreditInterestReceivable, a.Currency, a.Amount, nil)
+	j.credit(domain.AccountCreditInterestIncome, a.Currency, a.Amount, nil)
+	if len(j.Lines) > 0 {
+		a.JournalID = &j.ID
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.credit_interest_accruals (
+			id, facility_id, period_start, period_end, overdrawn, annual_rate, amount, journal_id, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (facility_id, period_end) DO NOTHING
+	`, a.ID, a.FacilityID, a.PeriodStart, a.PeriodEnd, a.Overdrawn, a.AnnualRate, a.Amount, a.JournalID, a.CreatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to record interest accrual")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.credit_facilities
+		SET accrued_interest = accrued_interest + $2, accrued_through = $3, updated_at = NOW()
+		WHERE id = $1
+	`, a.FacilityID, a.Amount, a.PeriodEnd); err != nil {
+		return false, errors.Wrap(err, "failed to add accrued interest")
+	}
+	if len(j.Lines) > 0 {
+		if err := s.writeJournals(ctx, tx, j); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	return true, nil
+}
+
+// CollectCreditInterest takes as much of a facility's accrued interest as
+// its wallet's balance covers, with a completed credit_interest
+// transaction, the wallet's entry and the journal settling the receivable,
+// in one database transaction. It fills in c and reports false, writing
+// nothing, when there is no interest owed or no balance to take it from.
+func (s *Service) CollectCreditInterest(ctx context.Context, c *domain.CreditInterestCollection) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	var (
+		userID, walletID uuid.UUID
+		accrued          decimal.Decimal
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, wallet_id, currency, accrued_interest FROM customer_schema.credit_facilities
+		WHERE id = $1
+		FOR UPDATE
+	`, c.FacilityID).Scan(&userID, &walletID, &c.Currency, &accrued)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to lock credit facility")
+	}
+	var available decimal.Decimal
+	if err := tx.QueryRowContext(ctx, `
+		SELECT available_balance FROM customer_schema.wallets WHERE id = $1 FOR UPDATE
+	`, walletID).Scan(&available); err != nil {
+		return false, errors.Wrap(err, "failed to lock wallet")
+	}
+	c.Amount = money(decimal.Min(accrued, available))
+	if !c.Amount.IsPositive() {
+		return false, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	c.TransactionID = uuid.New()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.transactions (
+			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			amount, currency, exchange_rate, converted_amount, converted_currency,
+			fee_amount, net_amount, status, transaction_type, description, metadata,
+			initiated_at, completed_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $3, $4, $4, $5, $6, 1, $5, $6, 0, $5, 'completed', $7, $8, $9, $10, $10, $10, $10)
+	`, c.TransactionID, "CRI-"+c.TransactionID.String(), userID, walletID, c.Amount, c.Currency,
+		string(domain.TransactionTypeCreditInterest), "Overdraft interest",
+		domain.Metadata{"facility_id": c.FacilityID.String(), "ledger_account": domain.AccountCreditInterestReceivable},
+		now); err != nil {
+		return false, errors.Wrap(err, "insert interest transaction failed")
+	}
+
+	var balanceAfter decimal.Decimal
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE customer_schema.wallets
+		SET
+			available_balance = available_balance - $1,
+			ledger_balance = ledger_balance - $1,
+			updated_at = NOW()
+		WHERE id = $2
+		RETURNING available_balance
+	`, c.Amount, walletID).Scan(&balanceAfter); err != nil {
+		return false, errors.Wrap(err, "interest wallet update failed")
+	}
+
+	entryID := uuid.New()
+	prevHash, err := s.getLastHash(ctx, tx, walletID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get previous hash")
+	}
+	hash := s.calculateHash(prevHash, entryID, c.TransactionID, walletID, "debit", c.Amount, c.Currency, balanceAfter, now)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_entries (
+			id, transaction_id, wallet_id, entry_type,
+			amount, currency, balance_after, created_at,
+			previous_hash, hash
+		) VALUES ($1, $2, $3, 'debit', $4, $5, $6, $7, $8, $9)
+	`, entryID, c.TransactionID, walletID, c.Amount, c.Currency, balanceAfter, now, prevHash, hash); err != nil {
+		return false, errors.Wrap(err, "insert interest ledger entry failed")
+	}
+
+	accounts, err := s.walletAccounts(ctx, tx, walletID)
+	if err != nil {
+		return false, err
+	}
+	j := newJournal(&c.TransactionID, "credit_interest_collection")
+	j.debit(walletAccount(accounts, walletID), c.Currency, c.Amount, &walletID)
+	j.credit(domain.AccountCreditInterestReceivable, c.Currency, c.Amount, nil)
+	if err := s.writeJournals(ctx, tx, j); err != nil {
+		return false, err
+	}
+	if err := s.ledgerRepo.CreateEntryTx(ctx, tx, c.TransactionID, "credit_interest", c.Amount, c.Currency, "completed"); err != nil {
+		return false, errors.Wrap(err, "failed to create immutable ledger entry")
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.credit_facilities
+		SET accrued_interest = accrued_interest - $2, interest_collected = interest_collected + $2, updated_at = NOW()
+		WHERE id = $1
+	`, c.FacilityID, c.Amount); err != nil {
+		return false, errors.Wrap(err, "failed to settle accrued interest")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	return true, nil
+}
@@ -0,0 +1,191 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// EscheatWallet moves the whole available balance of the dormant wallet
+// e.WalletID to the unclaimed balances account and records e, in one
+// database transaction with the escheatment transaction, its debit entry
+// and journal. Reserved funds stay where they are. It fills in the amount,
+// currency and transaction of e, and reports false, writing nothing, when
+// the wallet has no available balance.
+func (s *Service) EscheatWallet(ctx context.Context, e *domain.Escheatment) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	var available decimal.Decimal
+	err = tx.QueryRowContext(ctx, `
+		SELECT currency, available_balance FROM customer_schema.wallets
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, e.WalletID, e.UserID).Scan(&e.Currency, &available)
+	if err == sql.ErrNoRows {
+		return false, errors.ErrWalletNotFound
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to lock wallet")
+	}
+	e.Amount = money(available)
+	if !e.Amount.IsPositive() {
+		return false, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	e.TransactionID = uuid.New()
+	e.Status = domain.EscheatmentHeld
+	e.EscheatedAt = now
+	if err := s.postUnclaimed(ctx, tx, e.UserID, e.WalletID, e.TransactionID, e.Amount, e.Currency, true, now); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.escheatments (
+			id, user_id, wallet_id, country_code, amount, currency, status, transaction_id, escheated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, e.ID, e.UserID, e.WalletID, e.CountryCode, e.Amount, e.Currency, e.Status, e.TransactionID, e.EscheatedAt); err != nil {
+		return false, errors.Wrap(err, "failed to record escheatment")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	return true, nil
+}
+
+// ReturnEscheatment credits a held escheatment back to the wallet it came
+// from and marks it returned, in one database transaction. It reports
+// false, writing nothing, when the escheatment was already returned.
+func (s *Service) ReturnEscheatment(ctx context.Context, e *domain.Escheatment) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, wallet_id, amount, currency FROM customer_schema.escheatments
+		WHERE id = $1 AND status = $2
+		FOR UPDATE
+	`, e.ID, domain.EscheatmentHeld).Scan(&e.UserID, &e.WalletID, &e.Amount, &e.Currency)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to lock escheatment")
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM customer_schema.wallets WHERE id = $1 FOR UPDATE`, e.WalletID); err != nil {
+		return false, errors.Wrap(err, "failed to lock wallet")
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	returnID := uuid.New()
+	if err := s.postUnclaimed(ctx, tx, e.UserID, e.WalletID, returnID, e.Amount, e.Currency, false, now); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.escheatments
+		SET status = $2, return_transaction_id = $3, returned_at = $4
+		WHERE id = $1
+	`, e.ID, domain.EscheatmentReturned, returnID, now); err != nil {
+		return false, errors.Wrap(err, "failed to mark escheatment returned")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	e.Status = domain.EscheatmentReturned
+	e.ReturnTransactionID = &returnID
+	e.ReturnedAt = &now
+	return true, nil
+}
+
+// postUnclaimed writes the completed escheatment transaction txID moving
+// amount out of the locked wallet to unclaimed balances, or back, with the
+// wallet's entry and the journal.
+func (s *Service) postUnclaimed(ctx context.Context, tx *sqlx.Tx, owner, walletID, txID uuid.UUID, amount decimal.Decimal, currency domain.Currency, escheat bool, now time.Time) error {
+	entryType, memo, reference, delta := "credit", "escheatment_return", "ESR-", amount
+	if escheat {
+		entryType, memo, reference, delta = "debit", "escheatment", "ESC-", amount.Neg()
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.transactions (
+			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			amount, currency, exchange_rate, converted_amount, converted_currency,
+			fee_amount, net_amount, status, transaction_type, description, metadata,
+			initiated_at, completed_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $3, $4, $4, $5, $6, 1, $5, $6, 0, $5, 'completed', $7, $8, $9, $10, $10, $10, $10)
+	`, txID, reference+txID.String(), owner, walletID, amount, currency,
+		string(domain.TransactionTypeEscheatment), "Dormant balance "+memo,
+		domain.Metadata{"kind": memo, "ledger_account": domain.AccountUnclaimedBalances},
+		now); err != nil {
+		return errors.Wrap(err, "insert escheatment transaction failed")
+	}
+
+	var balanceAfter decimal.Decimal
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE customer_schema.wallets
+		SET
+			available_balance = available_balance + $1,
+			ledger_balance = ledger_balance + $1,
+			updated_at = NOW()
+		WHERE id = $2
+		RETURNING available_balance
+	`, delta, walletID).Scan(&balanceAfter); err != nil {
+		return errors.Wrap(err, "escheatment wallet update failed")
+	}
+
+	entryID := uuid.New()
+	prevHash, err := s.getLastHash(ctx, tx, walletID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get previous hash")
+	}
+	hash := s.calculateHash(prevHash, entryID, txID, walletID, entryType, amount, currency, balanceAfter, now)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_entries (
+			id, transaction_id, wallet_id, entry_type,
+			amount, currency, balance_after, created_at,
+			previous_hash, hash
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, entryID, txID, walletID, entryType, amount, currency, balanceAfter, now, prevHash, hash); err != nil {
+		return errors.Wrap(err, "insert escheatment ledger entry failed")
+	}
+
+	accounts, err := s.walletAccounts(ctx, tx, walletID)
+	if err != nil {
+		return err
+	}
+	if err := s.writeJournals(ctx, tx, unclaimedJournal(txID, walletID, walletAccount(accounts, walletID), amount, currency, escheat)); err != nil {
+		return err
+	}
+	if err := s.ledgerRepo.CreateEntryTx(ctx, tx, txID, memo, amount, currency, "completed"); err != nil {
+		return errors.Wrap(err, "failed to create immutable ledger entry")
+	}
+	return nil
+}
+
+// unclaimedJournal moves an escheated balance from the wallet's account to
+// unclaimed balances, or back.
+func unclaimedJournal(txID, walletID uuid.UUID, account string, amount decimal.Decimal, currency domain.Currency, escheat bool) *Journal {
+	if escheat {
+		j := newJournal(&txID, "escheatment")
+		j.debit(account, currency, amount, &walletID)
+		j.credit(domain.AccountUnclaimedBalances, currency, amount, nil)
+		return j
+	}
+	j := newJournal(&txID, "escheatment_return")
+	j.debit(domain.AccountUnclaimedBalances, currency, amount, nil)
+	j.credit(account, currency, amount, &walletID)
+	return j
+}
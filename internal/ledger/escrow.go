@@ -0,0 +1,235 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// Event types of escrow postings, which also tell their keys apart.
+const (
+	EscrowHold    = "escrow_hold"
+	EscrowRelease = "escrow_release"
+	EscrowRefund  = "escrow_refund"
+)
+
+// EscrowPosting moves the funds of an escrow: held in the sender's wallet,
+// paid out of the hold to the receiver, or handed back to the sender. Both
+// wallets are in Currency; an escrow never converts.
+type EscrowPosting struct {
+	TransactionID    uuid.UUID
+	SenderWalletID   uuid.UUID
+	ReceiverWalletID uuid.UUID
+	Amount           decimal.Decimal
+	Currency         domain.Currency
+	// IdempotencyKey is posted at most once, e.g. "escrow_hold:<transaction
+	// id>"; a retry returns the original receipt.
+	IdempotencyKey string
+}
+
+// posting is p as the ledger posting its key is claimed for, so that
+// reusing a key for a different movement is a conflict.
+func (p *EscrowPosting) posting(event string) *LedgerPosting {
+	return &LedgerPosting{
+		TransactionID:     p.TransactionID,
+		DebitWalletID:     p.SenderWalletID,
+		CreditWalletID:    p.ReceiverWalletID,
+		DebitAmount:       p.Amount,
+		CreditAmount:      p.Amount,
+		Currency:          p.Currency,
+		ConvertedCurrency: p.Currency,
+		EventType:         event,
+		IdempotencyKey:    p.IdempotencyKey,
+	}
+}
+
+// HoldEscrow moves the escrowed amount from the sender's available to its
+// reserved balance. The money stays the sender's, so no entry is written;
+// the key alone records the hold.
+func (s *Service) HoldEscrow(ctx context.Context, p *EscrowPosting) (*PostingReceipt, error) {
+	return s.postEscrow(ctx, p, EscrowHold, func(tx *sqlx.Tx, receipt *PostingReceipt) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE customer_schema.wallets
+			SET
+				available_balance = available_balance - $1,
+				reserved_balance = reserved_balance + $1,
+				updated_at = NOW()
+			WHERE id = $2 AND currency = $3 AND available_balance >= $1
+		`, p.Amount, p.SenderWalletID, p.Currency)
+		if err != nil {
+			return errors.Wrap(err, "hold escrow funds failed")
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errors.ErrInsufficientBalance
+		}
+		return nil
+	})
+}
+
+// ReleaseEscrow pays the held amount out of the sender's reserved balance
+// to the receiver, with a debit and a credit entry and the journal.
+func (s *Service) ReleaseEscrow(ctx context.Context, p *EscrowPosting) (*PostingReceipt, error) {
+	return s.postEscrow(ctx, p, EscrowRelease, func(tx *sqlx.Tx, receipt *PostingReceipt) error {
+		// Lock in a deterministic order, as Post does.
+		first, second := p.SenderWalletID, p.ReceiverWalletID
+		if first.String() > second.String() {
+			first, second = second, first
+		}
+		for _, id := range []uuid.UUID{first, second} {
+			if _, err := tx.ExecContext(ctx, `SELECT id FROM customer_schema.wallets WHERE id = $1 FOR UPDATE`, id); err != nil {
+				return errors.Wrap(err, "wallet lock failed")
+			}
+		}
+
+		var debitBalanceAfter decimal.Decimal
+		err := tx.QueryRowContext(ctx, `
+			UPDATE customer_schema.wallets
+			SET
+				reserved_balance = reserved_balance - $1,
+				ledger_balance = ledger_balance - $1,
+				updated_at = NOW()
+			WHERE id = $2 AND currency = $3 AND reserved_balance >= $1
+			RETURNING available_balance
+		`, p.Amount, p.SenderWalletID, p.Currency).Scan(&debitBalanceAfter)
+		if err == sql.ErrNoRows {
+			return errors.New("escrow hold not found on wallet in " + string(p.Currency))
+		}
+		if err != nil {
+			return errors.Wrap(err, "debit wallet update failed")
+		}
+
+		var creditBalanceAfter decimal.Decimal
+		err = tx.QueryRowContext(ctx, `
+			UPDATE customer_schema.wallets
+			SET
+				available_balance = available_balance + $1,
+				ledger_balance = ledger_balance + $1,
+				last_transaction_at = NOW(),
+				updated_at = NOW()
+			WHERE id = $2 AND currency = $3
+			RETURNING available_balance
+		`, p.Amount, p.ReceiverWalletID, p.Currency).Scan(&creditBalanceAfter)
+		if err == sql.ErrNoRows {
+			return errors.New("escrow receiver wallet not found in " + string(p.Currency))
+		}
+		if err != nil {
+			return errors.Wrap(err, "credit wallet update failed")
+		}
+
+		if receipt.DebitEntryID, err = s.writeEntry(ctx, tx, p.TransactionID, p.SenderWalletID, "debit", p.Amount, p.Currency, debitBalanceAfter); err != nil {
+			return err
+		}
+		if receipt.CreditEntryID, err = s.writeEntry(ctx, tx, p.TransactionID, p.ReceiverWalletID, "credit", p.Amount, p.Currency, creditBalanceAfter); err != nil {
+			return err
+		}
+
+		accounts, err := s.walletAccounts(ctx, tx, p.SenderWalletID, p.ReceiverWalletID)
+		if err != nil {
+			return err
+		}
+		if err := s.writeJournals(ctx, tx, postingJournal(p.posting(EscrowRelease), accounts)); err != nil {
+			return err
+		}
+		if err := s.ledgerRepo.CreateEntryTx(ctx, tx, p.TransactionID, EscrowRelease, p.Amount, p.Currency, "completed"); err != nil {
+			return errors.Wrap(err, "failed to create immutable ledger entry")
+		}
+		return nil
+	})
+}
+
+// RefundEscrow hands the held amount back to the sender's available
+// balance. Like the hold it writes no entry.
+func (s *Service) RefundEscrow(ctx context.Context, p *EscrowPosting) (*PostingReceipt, error) {
+	return s.postEscrow(ctx, p, EscrowRefund, func(tx *sqlx.Tx, receipt *PostingReceipt) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE customer_schema.wallets
+			SET
+				available_balance = available_balance + $1,
+				reserved_balance = reserved_balance - $1,
+				updated_at = NOW()
+			WHERE id = $2 AND currency = $3 AND reserved_balance >= $1
+		`, p.Amount, p.SenderWalletID, p.Currency)
+		if err != nil {
+			return errors.Wrap(err, "refund escrow funds failed")
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errors.New("escrow hold not found on wallet in " + string(p.Currency))
+		}
+		return nil
+	})
+}
+
+// postEscrow claims p's key and runs move in one serializable transaction,
+// retrying like Post when it loses a race with a concurrent duplicate.
+func (s *Service) postEscrow(ctx context.Context, p *EscrowPosting, event string, move func(tx *sqlx.Tx, receipt *PostingReceipt) error) (*PostingReceipt, error) {
+	if p.IdempotencyKey == "" {
+		return nil, errors.New("escrow posting needs an idempotency key")
+	}
+	for attempt := 1; ; attempt++ {
+		receipt, err := s.postEscrowOnce(ctx, p, event, move)
+		if err != nil && attempt < maxPostAttempts && isSerializationFailure(err) {
+			continue
+		}
+		return receipt, err
+	}
+}
+
+func (s *Service) postEscrowOnce(ctx context.Context, p *EscrowPosting, event string, move func(tx *sqlx.Tx, receipt *PostingReceipt) error) (*PostingReceipt, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	original, err := s.claimIdempotencyKey(ctx, tx, p.posting(event))
+	if err != nil {
+		return nil, err
+	}
+	if original != nil {
+		return original, nil
+	}
+
+	receipt := &PostingReceipt{
+		IdempotencyKey: p.IdempotencyKey,
+		TransactionID:  p.TransactionID,
+		PostedAt:       time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := move(tx, receipt); err != nil {
+		return nil, err
+	}
+	if err := s.recordReceipt(ctx, tx, receipt); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "transaction commit failed")
+	}
+	return receipt, nil
+}
+
+// writeEntry appends an entry to the wallet's hash chain.
+func (s *Service) writeEntry(ctx context.Context, tx *sqlx.Tx, txID, walletID uuid.UUID, entryType string, amount decimal.Decimal, currency domain.Currency, balanceAfter decimal.Decimal) (uuid.UUID, error) {
+	entryID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	prevHash, err := s.getLastHash(ctx, tx, walletID)
+	if err != nil {
+		return uuid.Nil, errors.Wrap(err, "failed to get "+entryType+" previous hash")
+	}
+	hash := s.calculateHash(prevHash, entryID, txID, walletID, entryType, amount, currency, balanceAfter, now)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_entries (
+			id, transaction_id, wallet_id, entry_type,
+			amount, currency, balance_after, created_at,
+			previous_hash, hash
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, entryID, txID, walletID, entryType, amount, currency, balanceAfter, now, prevHash, hash); err != nil {
+		return uuid.Nil, errors.Wrap(err, "insert "+entryType+" ledger entry failed")
+	}
+	return entryID, nil
+}
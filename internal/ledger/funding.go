@@ -0,0 +1,253 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// FundingPosting moves money between a wallet and outside the platform:
+// a confirmed mobile money or card top-up, or a withdrawal paid out.
+type FundingPosting struct {
+	TransactionID uuid.UUID
+	WalletID      uuid.UUID
+	Amount        decimal.Decimal
+	Currency      domain.Currency
+	// Metadata is merged into the transaction's metadata as it completes.
+	Metadata domain.Metadata
+}
+
+// PostFunding completes a pending funding transaction and credits its
+// wallet in one database transaction, so a top-up is never marked complete
+// without the money or credited twice. It reports false, writing nothing,
+// when the transaction is no longer pending.
+func (s *Service) PostFunding(ctx context.Context, p *FundingPosting) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	metadata := p.Metadata
+	if metadata == nil {
+		metadata = domain.Metadata{}
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.transactions
+		SET status = $1, completed_at = $2, updated_at = $2,
+			metadata = COALESCE(metadata, '{}'::jsonb) || $3::jsonb
+		WHERE id = $4 AND status = $5 AND receiver_wallet_id = $6
+	`, domain.TransactionStatusCompleted, now, metadata, p.TransactionID, domain.TransactionStatusPending, p.WalletID)
+	if err != nil {
+		return false, errors.Wrap(err, "complete funding transaction failed")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	var balanceAfter decimal.Decimal
+	err = tx.QueryRowContext(ctx, `
+		UPDATE customer_schema.wallets
+		SET
+			available_balance = available_balance + $1,
+			ledger_balance = ledger_balance + $1,
+			last_transaction_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $2 AND currency = $3
+		RETURNING available_balance
+	`, p.Amount, p.WalletID, p.Currency).Scan(&balanceAfter)
+	if err == sql.ErrNoRows {
+		return false, errors.New("funded wallet not found in " + string(p.Currency))
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "credit wallet update failed")
+	}
+
+	entryID := uuid.New()
+	prevHash, err := s.getLastHash(ctx, tx, p.WalletID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get credit previous hash")
+	}
+	hash := s.calculateHash(prevHash, entryID, p.TransactionID, p.WalletID, "credit", p.Amount, p.Currency, balanceAfter, now)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_entries (
+			id, transaction_id, wallet_id, entry_type,
+			amount, currency, balance_after, created_at,
+			previous_hash, hash
+		) VALUES ($1, $2, $3, 'credit', $4, $5, $6, $7, $8, $9)
+	`, entryID, p.TransactionID, p.WalletID, p.Amount, p.Currency, balanceAfter, now, prevHash, hash)
+	if err != nil {
+		return false, errors.Wrap(err, "insert credit ledger entry failed")
+	}
+
+	accounts, err := s.walletAccounts(ctx, tx, p.WalletID)
+	if err != nil {
+		return false, err
+	}
+	if err := s.writeJournals(ctx, tx, fundingJournal(p, walletAccount(accounts, p.WalletID), true)); err != nil {
+		return false, err
+	}
+
+	if err := s.ledgerRepo.CreateEntryTx(ctx, tx, p.TransactionID, "deposit", p.Amount, p.Currency, "completed"); err != nil {
+		return false, errors.Wrap(err, "failed to create immutable ledger entry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	return true, nil
+}
+
+// PostWithdrawal completes a withdrawal the payout provider confirmed: the
+// transaction completes and the reserved funds leave the wallet, with a
+// debit entry, in one database transaction. It reports false, writing
+// nothing, when the withdrawal is no longer processing.
+func (s *Service) PostWithdrawal(ctx context.Context, p *FundingPosting) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	metadata := p.Metadata
+	if metadata == nil {
+		metadata = domain.Metadata{}
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.transactions
+		SET status = $1, completed_at = $2, updated_at = $2,
+			metadata = COALESCE(metadata, '{}'::jsonb) || $3::jsonb
+		WHERE id = $4 AND status = $5 AND sender_wallet_id = $6
+	`, domain.TransactionStatusCompleted, now, metadata, p.TransactionID, domain.TransactionStatusProcessing, p.WalletID)
+	if err != nil {
+		return false, errors.Wrap(err, "complete withdrawal transaction failed")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	var balanceAfter decimal.Decimal
+	err = tx.QueryRowContext(ctx, `
+		UPDATE customer_schema.wallets
+		SET
+			reserved_balance = reserved_balance - $1,
+			ledger_balance = ledger_balance - $1,
+			last_transaction_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $2 AND currency = $3 AND reserved_balance >= $1
+		RETURNING available_balance
+	`, p.Amount, p.WalletID, p.Currency).Scan(&balanceAfter)
+	if err == sql.ErrNoRows {
+		return false, errors.New("withdrawal reservation not found on wallet in " + string(p.Currency))
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "debit wallet update failed")
+	}
+
+	entryID := uuid.New()
+	prevHash, err := s.getLastHash(ctx, tx, p.WalletID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get debit previous hash")
+	}
+	hash := s.calculateHash(prevHash, entryID, p.TransactionID, p.WalletID, "debit", p.Amount, p.Currency, balanceAfter, now)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_entries (
+			id, transaction_id, wallet_id, entry_type,
+			amount, currency, balance_after, created_at,
+			previous_hash, hash
+		) VALUES ($1, $2, $3, 'debit', $4, $5, $6, $7, $8, $9)
+	`, entryID, p.TransactionID, p.WalletID, p.Amount, p.Currency, balanceAfter, now, prevHash, hash)
+	if err != nil {
+		return false, errors.Wrap(err, "insert debit ledger entry failed")
+	}
+
+	accounts, err := s.walletAccounts(ctx, tx, p.WalletID)
+	if err != nil {
+		return false, err
+	}
+	if err := s.writeJournals(ctx, tx, fundingJournal(p, walletAccount(accounts, p.WalletID), false)); err != nil {
+		return false, err
+	}
+
+	if err := s.ledgerRepo.CreateEntryTx(ctx, tx, p.TransactionID, "withdrawal", p.Amount, p.Currency, "completed"); err != nil {
+		return false, errors.Wrap(err, "failed to create immutable ledger entry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	return true, nil
+}
+
+// CancelWithdrawal fails a withdrawal that was rejected or could not be
+// paid out and returns its reserved funds to the wallet's available
+// balance, in one database transaction. It reports false, writing
+// nothing, when the withdrawal has already completed or failed.
+func (s *Service) CancelWithdrawal(ctx context.Context, p *FundingPosting, reason string) (bool, error) {
+	return s.cancelWithdrawal(ctx, p, reason, domain.TransactionStatusPending, domain.TransactionStatusPendingApproval, domain.TransactionStatusProcessing)
+}
+
+// ReleaseWithdrawal is CancelWithdrawal for a withdrawal that was never
+// sent to its payout provider: it reports false, writing nothing, once the
+// withdrawal has left pending.
+func (s *Service) ReleaseWithdrawal(ctx context.Context, p *FundingPosting, reason string) (bool, error) {
+	return s.cancelWithdrawal(ctx, p, reason, domain.TransactionStatusPending)
+}
+
+func (s *Service) cancelWithdrawal(ctx context.Context, p *FundingPosting, reason string, from ...domain.TransactionStatus) (bool, error) {
+	statuses := make([]string, len(from))
+	for i, status := range from {
+		statuses[i] = string(status)
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	metadata := p.Metadata
+	if metadata == nil {
+		metadata = domain.Metadata{}
+	}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.transactions
+		SET status = $1, status_reason = $2, updated_at = NOW(),
+			metadata = COALESCE(metadata, '{}'::jsonb) || $3::jsonb
+		WHERE id = $4 AND sender_wallet_id = $5 AND status = ANY($6)
+	`, domain.TransactionStatusFailed, reason, metadata, p.TransactionID, p.WalletID, pq.Array(statuses))
+	if err != nil {
+		return false, errors.Wrap(err, "fail withdrawal transaction failed")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		UPDATE customer_schema.wallets
+		SET
+			available_balance = available_balance + $1,
+			reserved_balance = reserved_balance - $1,
+			updated_at = NOW()
+		WHERE id = $2 AND currency = $3 AND reserved_balance >= $1
+	`, p.Amount, p.WalletID, p.Currency)
+	if err != nil {
+		return false, errors.Wrap(err, "release reserved funds failed")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, errors.New("withdrawal reservation not found on wallet in " + string(p.Currency))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "transaction commit failed")
+	}
+	return true, nil
+}
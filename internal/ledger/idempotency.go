@@ -0,0 +1,125 @@
+package ledger
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrIdempotencyConflict is returned when an idempotency key is reused for a
+// posting that differs from the one originally posted under it.
+var ErrIdempotencyConflict = errors.New("idempotency key already used for a different posting")
+
+// maxPostAttempts bounds retries of a keyed posting that lost a race with a
+// concurrent post of the same key.
+const maxPostAttempts = 3
+
+// PostingReceipt identifies the ledger entries written by a posting.
+type PostingReceipt struct {
+	IdempotencyKey string     `json:"idempotency_key,omitempty"`
+	TransactionID  uuid.UUID  `json:"transaction_id"`
+	DebitEntryID   uuid.UUID  `json:"debit_entry_id"`
+	CreditEntryID  uuid.UUID  `json:"credit_entry_id"`
+	FeeEntryID     *uuid.UUID `json:"fee_entry_id,omitempty"`
+	PostedAt       time.Time  `json:"posted_at"`
+	// Replayed is set when the receipt is that of an earlier posting with
+	// the same key and nothing new was written.
+	Replayed bool `json:"replayed"`
+}
+
+// fingerprint hashes the money-moving fields of a posting so a replay can be
+// told apart from a different posting that reuses the key.
+func (p *LedgerPosting) fingerprint() string {
+	fee := ""
+	if p.FeeWalletID != nil {
+		fee = p.FeeWalletID.String()
+	}
+	data := fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s|%s|%s|%s",
+		p.TransactionID, p.DebitWalletID, p.CreditWalletID, fee,
+		p.DebitAmount.String(), p.CreditAmount.String(), p.FeeAmount.String(),
+		p.Currency, p.ConvertedCurrency, p.EventType,
+	)
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimIdempotencyKey reserves posting.IdempotencyKey within tx. It returns
+// nil when the key is new, or the original receipt when it was posted before.
+func (s *Service) claimIdempotencyKey(ctx context.Context, tx *sqlx.Tx, posting *LedgerPosting) (*PostingReceipt, error) {
+	fingerprint := posting.fingerprint()
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_postings (idempotency_key, transaction_id, fingerprint, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`, posting.IdempotencyKey, posting.TransactionID, fingerprint)
+	if err != nil {
+		return nil, errors.Wrap(err, "claim idempotency key failed")
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil, nil
+	}
+
+	var (
+		original      PostingReceipt
+		originalPrint string
+		debit, credit uuid.NullUUID
+		fee           uuid.NullUUID
+		postedAt      sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT transaction_id, fingerprint, debit_entry_id, credit_entry_id, fee_entry_id, posted_at
+		FROM customer_schema.ledger_postings
+		WHERE idempotency_key = $1
+	`, posting.IdempotencyKey).Scan(&original.TransactionID, &originalPrint, &debit, &credit, &fee, &postedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "load idempotent posting failed")
+	}
+	if originalPrint != fingerprint {
+		return nil, ErrIdempotencyConflict
+	}
+	original.IdempotencyKey = posting.IdempotencyKey
+	original.DebitEntryID = debit.UUID
+	original.CreditEntryID = credit.UUID
+	if fee.Valid {
+		original.FeeEntryID = &fee.UUID
+	}
+	original.PostedAt = postedAt.Time
+	original.Replayed = true
+	return &original, nil
+}
+
+// recordReceipt stores the entries written under a claimed key. Postings
+// that write no entry, such as an escrow hold, leave them empty.
+func (s *Service) recordReceipt(ctx context.Context, tx *sqlx.Tx, r *PostingReceipt) error {
+	entry := func(id uuid.UUID) interface{} {
+		if id == uuid.Nil {
+			return nil
+		}
+		return id
+	}
+	var fee interface{}
+	if r.FeeEntryID != nil {
+		fee = *r.FeeEntryID
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.ledger_postings
+		SET debit_entry_id = $2, credit_entry_id = $3, fee_entry_id = $4, posted_at = $5
+		WHERE idempotency_key = $1
+	`, r.IdempotencyKey, entry(r.DebitEntryID), entry(r.CreditEntryID), fee, r.PostedAt)
+	return errors.Wrap(err, "record idempotent posting failed")
+}
+
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return stderrors.As(err, &pqErr) && pqErr.Code == "40001"
+}
@@ -0,0 +1,242 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// ErrUnbalancedJournal is returned when a posting's journal does not have
+// equal debits and credits in every currency; nothing is posted.
+var ErrUnbalancedJournal = errors.New("journal does not balance")
+
+// JournalLine is one side of a journal against an account in the chart.
+// Exactly one of Debit and Credit is positive.
+type JournalLine struct {
+	Account  string
+	WalletID *uuid.UUID
+	Currency domain.Currency
+	Debit    decimal.Decimal
+	Credit   decimal.Decimal
+}
+
+// Journal is the general ledger view of one posting: the wallet entries
+// plus the system accounts the money moved through. Every journal balances
+// per currency.
+type Journal struct {
+	ID            uuid.UUID
+	TransactionID *uuid.UUID
+	Memo          string
+	Lines         []JournalLine
+}
+
+func newJournal(transactionID *uuid.UUID, memo string) *Journal {
+	return &Journal{ID: uuid.New(), TransactionID: transactionID, Memo: memo}
+}
+
+// money rounds an amount to the precision balances are stored at, so the
+// journal balances to the cent the database keeps.
+func money(d decimal.Decimal) decimal.Decimal {
+	return d.Round(2)
+}
+
+// debit adds a debit line; a negative amount is booked as a credit and a
+// zero amount is dropped.
+func (j *Journal) debit(account string, currency domain.Currency, amount decimal.Decimal, walletID *uuid.UUID) {
+	amount = money(amount)
+	switch {
+	case amount.IsPositive():
+		j.Lines = append(j.Lines, JournalLine{Account: account, WalletID: walletID, Currency: currency, Debit: amount, Credit: decimal.Zero})
+	case amount.IsNegative():
+		j.credit(account, currency, amount.Neg(), walletID)
+	}
+}
+
+// credit adds a credit line; a negative amount is booked as a debit and a
+// zero amount is dropped.
+func (j *Journal) credit(account string, currency domain.Currency, amount decimal.Decimal, walletID *uuid.UUID) {
+	amount = money(amount)
+	switch {
+	case amount.IsPositive():
+		j.Lines = append(j.Lines, JournalLine{Account: account, WalletID: walletID, Currency: currency, Debit: decimal.Zero, Credit: amount})
+	case amount.IsNegative():
+		j.debit(account, currency, amount.Neg(), walletID)
+	}
+}
+
+// Validate checks that every line posts to an account in the chart and
+// that debits equal credits in every currency.
+func (j *Journal) Validate() error {
+	if len(j.Lines) < 2 {
+		return fmt.Errorf("%w: a journal needs at least two lines", ErrUnbalancedJournal)
+	}
+	net := make(map[domain.Currency]decimal.Decimal)
+	for _, l := range j.Lines {
+		if _, ok := domain.LookupLedgerAccount(l.Account); !ok {
+			return fmt.Errorf("%w: unknown account %q", ErrUnbalancedJournal, l.Account)
+		}
+		net[l.Currency] = net[l.Currency].Add(l.Debit).Sub(l.Credit)
+	}
+	currencies := make([]string, 0, len(net))
+	for c := range net {
+		currencies = append(currencies, string(c))
+	}
+	sort.Strings(currencies)
+	for _, c := range currencies {
+		if d := net[domain.Currency(c)]; !d.IsZero() {
+			return fmt.Errorf("%w: %s debits exceed credits by %s", ErrUnbalancedJournal, c, d)
+		}
+	}
+	return nil
+}
+
+// postingJournal books p against the chart. The sender's wallet is debited
+// and the receiver's credited, each under the account of its owner, and
+// the fee is credited to the fee wallet or, without one, straight to fee
+// income. A conversion passes through settlement clearing: the sender's
+// currency is credited to it and the receiver's debited from it at the mid
+// rate, the difference from what the receiver got being FX gain or loss.
+// Without a mid rate the conversion is booked at the customer rate.
+func postingJournal(p *LedgerPosting, accounts map[uuid.UUID]string) *Journal {
+	txID := p.TransactionID
+	memo := p.EventType
+	if memo == "" {
+		memo = "payment"
+	}
+	j := newJournal(&txID, memo)
+	debitWallet, creditWallet := p.DebitWalletID, p.CreditWalletID
+	j.debit(walletAccount(accounts, debitWallet), p.Currency, p.DebitAmount, &debitWallet)
+
+	fee := decimal.Zero
+	if p.FeeAmount.IsPositive() {
+		fee = p.FeeAmount
+		if p.FeeWalletID != nil {
+			feeWallet := *p.FeeWalletID
+			j.credit(walletAccount(accounts, feeWallet), p.Currency, fee, &feeWallet)
+		} else {
+			j.credit(domain.AccountFeeIncome, p.Currency, fee, nil)
+		}
+	}
+
+	if p.Currency == p.ConvertedCurrency {
+		j.credit(walletAccount(accounts, creditWallet), p.ConvertedCurrency, p.CreditAmount, &creditWallet)
+		return j
+	}
+
+	converted := money(p.DebitAmount).Sub(money(fee))
+	j.credit(domain.AccountSettlementClearing, p.Currency, converted, nil)
+	atMid := money(p.CreditAmount)
+	if p.MidRate.IsPositive() {
+		atMid = money(converted.Mul(p.MidRate))
+	}
+	j.debit(domain.AccountSettlementClearing, p.ConvertedCurrency, atMid, nil)
+	j.credit(walletAccount(accounts, creditWallet), p.ConvertedCurrency, p.CreditAmount, &creditWallet)
+	j.credit(domain.AccountFXGainLoss, p.ConvertedCurrency, atMid.Sub(money(p.CreditAmount)), nil)
+	return j
+}
+
+// fundingJournal books money arriving from, or leaving to, a provider.
+func fundingJournal(p *FundingPosting, account string, deposit bool) *Journal {
+	txID, walletID := p.TransactionID, p.WalletID
+	if deposit {
+		j := newJournal(&txID, "deposit")
+		j.debit(domain.AccountProviderClearing, p.Currency, p.Amount, nil)
+		j.credit(account, p.Currency, p.Amount, &walletID)
+		return j
+	}
+	j := newJournal(&txID, "withdrawal")
+	j.debit(account, p.Currency, p.Amount, &walletID)
+	j.credit(domain.AccountProviderClearing, p.Currency, p.Amount, nil)
+	return j
+}
+
+// walletAccount is the account a wallet's money sits in: customer wallets
+// unless its owner is a house user.
+func walletAccount(accounts map[uuid.UUID]string, walletID uuid.UUID) string {
+	if a, ok := accounts[walletID]; ok {
+		return a
+	}
+	return domain.AccountCustomerWallets
+}
+
+// walletAccounts returns the account of each of walletIDs whose owner is a
+// house user; the rest are customer wallets.
+func (s *Service) walletAccounts(ctx context.Context, tx *sqlx.Tx, walletIDs ...uuid.UUID) (map[uuid.UUID]string, error) {
+	ids := make([]string, len(walletIDs))
+	for i, id := range walletIDs {
+		ids[i] = id.String()
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT w.id, h.account_code
+		FROM customer_schema.wallets w
+		JOIN customer_schema.ledger_house_users h ON h.user_id = w.user_id
+		WHERE w.id = ANY($1::uuid[])
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up wallet accounts")
+	}
+	defer rows.Close()
+	accounts := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var account string
+		if err := rows.Scan(&id, &account); err != nil {
+			return nil, errors.Wrap(err, "failed to look up wallet accounts")
+		}
+		accounts[id] = account
+	}
+	return accounts, errors.Wrap(rows.Err(), "failed to look up wallet accounts")
+}
+
+// writeJournals validates journals and inserts their lines. The database
+// checks again that each journal balances when the transaction commits.
+func (s *Service) writeJournals(ctx context.Context, tx *sqlx.Tx, journals ...*Journal) error {
+	var journalIDs, txIDs, accounts, walletIDs, currencies, debits, credits, memos []string
+	for _, j := range journals {
+		if err := j.Validate(); err != nil {
+			return err
+		}
+		for _, l := range j.Lines {
+			journalIDs = append(journalIDs, j.ID.String())
+			txIDs = append(txIDs, uuidOrEmpty(j.TransactionID))
+			accounts = append(accounts, l.Account)
+			walletIDs = append(walletIDs, uuidOrEmpty(l.WalletID))
+			currencies = append(currencies, string(l.Currency))
+			debits = append(debits, l.Debit.String())
+			credits = append(credits, l.Credit.String())
+			memos = append(memos, j.Memo)
+		}
+	}
+	if len(journalIDs) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.journal_lines (
+			journal_id, transaction_id, account_code, wallet_id,
+			currency, debit, credit, memo, created_at
+		)
+		SELECT v.journal_id::uuid, NULLIF(v.transaction_id, '')::uuid, v.account_code, NULLIF(v.wallet_id, '')::uuid,
+			v.currency, v.debit, v.credit, v.memo, $9
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::numeric[], $7::numeric[], $8::text[])
+			AS v(journal_id, transaction_id, account_code, wallet_id, currency, debit, credit, memo)
+	`, pq.Array(journalIDs), pq.Array(txIDs), pq.Array(accounts), pq.Array(walletIDs),
+		pq.Array(currencies), pq.Array(debits), pq.Array(credits), pq.Array(memos),
+		time.Now().UTC().Truncate(time.Microsecond))
+	return errors.Wrap(err, "insert journal lines failed")
+}
+
+func uuidOrEmpty(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,164 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+// balances sums a journal's lines into signed debit balances per account
+// and currency.
+func balances(j *Journal) map[string]string {
+	out := make(map[string]decimal.Decimal)
+	for _, l := range j.Lines {
+		k := l.Account + " " + string(l.Currency)
+		out[k] = out[k].Add(l.Debit).Sub(l.Credit)
+	}
+	s := make(map[string]string, len(out))
+	for k, v := range out {
+		s[k] = v.String()
+	}
+	return s
+}
+
+func TestPostingJournal_SameCurrency(t *testing.T) {
+	sender, receiver, fees := uuid.New(), uuid.New(), uuid.New()
+	p := &LedgerPosting{
+		TransactionID:  uuid.New(),
+		DebitWalletID:  sender,
+		CreditWalletID: receiver,
+		DebitAmount:    d("101.50"), CreditAmount: d("100"), FeeAmount: d("1.5"),
+		Currency: "MWK", ConvertedCurrency: "MWK",
+	}
+
+	j := postingJournal(p, nil)
+	require.NoError(t, j.Validate())
+	assert.Equal(t, "payment", j.Memo)
+	assert.Equal(t, map[string]string{
+		"2010 MWK": "1.5",
+		"4010 MWK": "-1.5",
+	}, balances(j), "without a fee wallet the fee is fee income")
+	assert.Nil(t, j.Lines[1].WalletID)
+
+	// The fee collector's wallet is fee income too.
+	p.FeeWalletID = &fees
+	j = postingJournal(p, map[uuid.UUID]string{fees: domain.AccountFeeIncome})
+	require.NoError(t, j.Validate())
+	assert.Equal(t, map[string]string{
+		"2010 MWK": "1.5",
+		"4010 MWK": "-1.5",
+	}, balances(j))
+	require.Len(t, j.Lines, 3)
+	assert.Equal(t, &fees, j.Lines[1].WalletID)
+
+	// Money that appears from nowhere does not post.
+	p.CreditAmount = d("101")
+	assert.ErrorIs(t, postingJournal(p, nil).Validate(), ErrUnbalancedJournal)
+}
+
+func TestPostingJournal_Conversion(t *testing.T) {
+	p := &LedgerPosting{
+		TransactionID:  uuid.New(),
+		DebitWalletID:  uuid.New(),
+		CreditWalletID: uuid.New(),
+		DebitAmount:    d("101.50"), CreditAmount: d("1712.00"), FeeAmount: d("1.50"),
+		Currency: "USD", ConvertedCurrency: "MWK",
+		ExchangeRate: d("17.12"), MidRate: d("17.50"),
+	}
+
+	j := postingJournal(p, nil)
+	require.NoError(t, j.Validate())
+	assert.Equal(t, map[string]string{
+		"2010 USD": "101.5",
+		"4010 USD": "-1.5",
+		"1010 USD": "-100",
+		"1010 MWK": "1750",
+		"2010 MWK": "-1712",
+		"4020 MWK": "-38",
+	}, balances(j), "the spread against the mid rate is FX gain")
+
+	// Without a mid rate the conversion is booked at the customer rate.
+	p.MidRate = decimal.Zero
+	j = postingJournal(p, nil)
+	require.NoError(t, j.Validate())
+	assert.Equal(t, "1712", balances(j)["1010 MWK"])
+	_, fx := balances(j)["4020 MWK"]
+	assert.False(t, fx)
+
+	// A customer rate better than mid is an FX loss.
+	p.MidRate, p.CreditAmount = d("17"), d("1712")
+	assert.Equal(t, "12", balances(postingJournal(p, nil))["4020 MWK"])
+}
+
+func TestFundingJournal(t *testing.T) {
+	p := &FundingPosting{TransactionID: uuid.New(), WalletID: uuid.New(), Amount: d("250"), Currency: "MWK"}
+
+	deposit := fundingJournal(p, domain.AccountCustomerWallets, true)
+	require.NoError(t, deposit.Validate())
+	assert.Equal(t, map[string]string{"1020 MWK": "250", "2010 MWK": "-250"}, balances(deposit))
+
+	withdrawal := fundingJournal(p, domain.AccountCustomerWallets, false)
+	require.NoError(t, withdrawal.Validate())
+	assert.Equal(t, map[string]string{"1020 MWK": "-250", "2010 MWK": "250"}, balances(withdrawal))
+}
+
+func TestUnclaimedJournal(t *testing.T) {
+	txID, walletID := uuid.New(), uuid.New()
+
+	escheat := unclaimedJournal(txID, walletID, domain.AccountCustomerWallets, d("80.25"), "MWK", true)
+	require.NoError(t, escheat.Validate())
+	assert.Equal(t, map[string]string{"2010 MWK": "80.25", "2020 MWK": "-80.25"}, balances(escheat))
+
+	back := unclaimedJournal(txID, walletID, domain.AccountCustomerWallets, d("80.25"), "MWK", false)
+	require.NoError(t, back.Validate())
+	assert.Equal(t, map[string]string{"2010 MWK": "-80.25", "2020 MWK": "80.25"}, balances(back))
+}
+
+func TestJournalValidate(t *testing.T) {
+	j := newJournal(nil, "test")
+	j.debit(domain.AccountCustomerWallets, "MWK", d("10"), nil)
+	assert.ErrorIs(t, j.Validate(), ErrUnbalancedJournal, "one line")
+
+	j.credit("9999", "MWK", d("10"), nil)
+	assert.ErrorIs(t, j.Validate(), ErrUnbalancedJournal, "unknown account")
+
+	j.Lines = j.Lines[:1]
+	j.credit(domain.AccountFeeIncome, "USD", d("10"), nil)
+	assert.ErrorIs(t, j.Validate(), ErrUnbalancedJournal, "balances across currencies only")
+
+	j.Lines = j.Lines[:1]
+	j.credit(domain.AccountFeeIncome, "MWK", d("10.004"), nil)
+	assert.NoError(t, j.Validate(), "amounts are kept to the cent")
+
+	j.debit(domain.AccountFXGainLoss, "MWK", decimal.Zero, nil)
+	assert.Len(t, j.Lines, 2, "zero lines are dropped")
+	j.debit(domain.AccountFXGainLoss, "MWK", d("-3"), nil)
+	assert.True(t, j.Lines[2].Credit.Equal(d("3")), "negative debits are credits")
+}
+
+func TestNewTrialBalance(t *testing.T) {
+	asOf := time.Now()
+	tb := newTrialBalance(asOf, []domain.TrialBalanceLine{
+		{AccountCode: "1020", AccountType: domain.LedgerAccountAsset, Currency: "MWK", Debit: d("500"), Credit: d("200")},
+		{AccountCode: "2010", AccountType: domain.LedgerAccountLiability, Currency: "MWK", Debit: d("200"), Credit: d("480")},
+		{AccountCode: "4010", AccountType: domain.LedgerAccountIncome, Currency: "MWK", Credit: d("20")},
+		{AccountCode: "2010", AccountType: domain.LedgerAccountLiability, Currency: "USD", Credit: d("5")},
+	})
+
+	assert.Equal(t, "300", tb.Lines[0].Balance.String())
+	assert.Equal(t, "280", tb.Lines[1].Balance.String())
+	assert.Equal(t, "20", tb.Lines[2].Balance.String())
+	require.Len(t, tb.Totals, 2)
+	assert.Equal(t, domain.Currency("MWK"), tb.Totals[0].Currency)
+	assert.True(t, tb.Totals[0].Balanced)
+	assert.False(t, tb.Totals[1].Balanced)
+	assert.False(t, tb.Balanced)
+}
@@ -0,0 +1,261 @@
+package ledger
+
+import (
+	stderrors "errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// history is a generated sequence of journal batches over a few wallets:
+// payments with fees, partial refunds and full reversals, some of which
+// overdraw their wallet and must be rejected as a whole batch.
+type history struct {
+	wallets []uuid.UUID
+	fees    uuid.UUID
+	opening map[uuid.UUID]decimal.Decimal
+	batches [][]*LedgerPosting
+}
+
+func (history) Generate(r *rand.Rand, size int) reflect.Value {
+	h := history{fees: uuid.New(), opening: make(map[uuid.UUID]decimal.Decimal)}
+	for i := 0; i < 2+r.Intn(4); i++ {
+		w := uuid.New()
+		h.wallets = append(h.wallets, w)
+		h.opening[w] = cents(r.Int63n(1000000))
+	}
+	h.opening[h.fees] = decimal.Zero
+
+	type paid struct {
+		from, to    uuid.UUID
+		amount, fee decimal.Decimal
+	}
+	var payments []paid
+	for b := 0; b < 1+r.Intn(size+1); b++ {
+		var batch []*LedgerPosting
+		for p := 0; p < 1+r.Intn(4); p++ {
+			switch k := r.Intn(10); {
+			case k < 6 || len(payments) == 0:
+				from, to := h.pair(r)
+				amount, fee := cents(1+r.Int63n(400000)), cents(r.Int63n(500))
+				batch = append(batch, posting(from, to, amount.Add(fee), amount, &h.fees, fee))
+				payments = append(payments, paid{from, to, amount, fee})
+			case k < 8:
+				// Partial refund of an earlier payment.
+				pay := payments[r.Intn(len(payments))]
+				part := pay.amount.Mul(decimal.NewFromFloat(r.Float64())).Round(2)
+				if part.IsPositive() {
+					batch = append(batch, posting(pay.to, pay.from, part, part, nil, decimal.Zero))
+				}
+			default:
+				// Reversal: the net amount back from the receiver and the
+				// fee back from the fee wallet.
+				pay := payments[r.Intn(len(payments))]
+				batch = append(batch, posting(pay.to, pay.from, pay.amount, pay.amount, nil, decimal.Zero))
+				if pay.fee.IsPositive() {
+					batch = append(batch, posting(h.fees, pay.from, pay.fee, pay.fee, nil, decimal.Zero))
+				}
+			}
+		}
+		if len(batch) > 0 {
+			h.batches = append(h.batches, batch)
+		}
+	}
+	return reflect.ValueOf(h)
+}
+
+func (h history) pair(r *rand.Rand) (uuid.UUID, uuid.UUID) {
+	i := r.Intn(len(h.wallets))
+	j := (i + 1 + r.Intn(len(h.wallets)-1)) % len(h.wallets)
+	return h.wallets[i], h.wallets[j]
+}
+
+func cents(n int64) decimal.Decimal { return decimal.New(n, -2) }
+
+func posting(from, to uuid.UUID, debit, credit decimal.Decimal, feeWallet *uuid.UUID, fee decimal.Decimal) *LedgerPosting {
+	p := &LedgerPosting{
+		TransactionID:     uuid.New(),
+		DebitWalletID:     from,
+		CreditWalletID:    to,
+		DebitAmount:       debit,
+		CreditAmount:      credit,
+		FeeAmount:         fee,
+		Currency:          "MWK",
+		ConvertedCurrency: "MWK",
+	}
+	if fee.IsPositive() {
+		p.FeeWalletID = feeWallet
+	}
+	return p
+}
+
+// replay applies h through buildJournal the way PostBatch does, one batch at
+// a time against the committed balances and chain heads, and checks the
+// money invariants after every batch.
+func (h history) replay() error {
+	s := &Service{}
+	balances := copyBalances(h.opening)
+	heads := make(map[uuid.UUID]string)
+	chains := make(map[uuid.UUID][]ledgerEntry)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for b, batch := range h.batches {
+		if err := validateBatch(batch); err != nil {
+			return fmt.Errorf("batch %d: generated an invalid batch: %v", b, err)
+		}
+		// A reference model: apply each posting with plain arithmetic.
+		model := copyBalances(balances)
+		overdrawn := false
+		for _, p := range batch {
+			if model[p.DebitWalletID].LessThan(p.DebitAmount) {
+				overdrawn = true
+				break
+			}
+			model[p.DebitWalletID] = model[p.DebitWalletID].Sub(p.DebitAmount)
+			model[p.CreditWalletID] = model[p.CreditWalletID].Add(p.CreditAmount)
+			if p.FeeWalletID != nil {
+				model[*p.FeeWalletID] = model[*p.FeeWalletID].Add(p.FeeAmount)
+			}
+		}
+
+		locked, lockedHeads := copyBalances(balances), copyHeads(heads)
+		j, err := s.buildJournal(batch, locked, lockedHeads, base.Add(time.Duration(b)*time.Second))
+		if overdrawn {
+			if !stderrors.Is(err, errors.ErrInsufficientBalance) {
+				return fmt.Errorf("batch %d: overdraft accepted (err %v)", b, err)
+			}
+			continue // rolled back: committed state is untouched
+		}
+		if err != nil {
+			return fmt.Errorf("batch %d: rejected a covered batch: %v", b, err)
+		}
+
+		for w, want := range model {
+			if !locked[w].Equal(want) {
+				return fmt.Errorf("batch %d: wallet %s balance %s, model says %s", b, w, locked[w], want)
+			}
+		}
+		deltas := make(map[uuid.UUID]decimal.Decimal)
+		for _, e := range j.entries {
+			if e.EntryType == "debit" {
+				deltas[e.WalletID] = deltas[e.WalletID].Sub(e.Amount)
+			} else {
+				deltas[e.WalletID] = deltas[e.WalletID].Add(e.Amount)
+			}
+			chains[e.WalletID] = append(chains[e.WalletID], e)
+		}
+		for w, d := range deltas {
+			if !j.deltas[w].Equal(d) {
+				return fmt.Errorf("batch %d: wallet %s delta %s, entries sum to %s", b, w, j.deltas[w], d)
+			}
+		}
+		balances, heads = locked, lockedHeads
+
+		if err := h.checkInvariants(balances, chains); err != nil {
+			return fmt.Errorf("after batch %d: %v", b, err)
+		}
+	}
+	return h.checkChains(s, chains)
+}
+
+func (h history) checkInvariants(balances map[uuid.UUID]decimal.Decimal, chains map[uuid.UUID][]ledgerEntry) error {
+	total, opening := decimal.Zero, decimal.Zero
+	for w, bal := range balances {
+		if bal.IsNegative() {
+			return fmt.Errorf("wallet %s is negative: %s", w, bal)
+		}
+		// The ledger sums to the wallet balance and the last entry agrees.
+		sum := h.opening[w]
+		for _, e := range chains[w] {
+			if e.EntryType == "debit" {
+				sum = sum.Sub(e.Amount)
+			} else {
+				sum = sum.Add(e.Amount)
+			}
+		}
+		if !sum.Equal(bal) {
+			return fmt.Errorf("wallet %s: ledger sums to %s, balance is %s", w, sum, bal)
+		}
+		if n := len(chains[w]); n > 0 && !chains[w][n-1].BalanceAfter.Equal(bal) {
+			return fmt.Errorf("wallet %s: last entry balance %s, balance is %s", w, chains[w][n-1].BalanceAfter, bal)
+		}
+		total = total.Add(bal)
+		opening = opening.Add(h.opening[w])
+	}
+	// Single-currency postings only move money around.
+	if !total.Equal(opening) {
+		return fmt.Errorf("money not conserved: %s, opened with %s", total, opening)
+	}
+	return nil
+}
+
+// checkChains walks every wallet's chain in read order, as
+// VerifyChainIntegrity does, then checks that tampering with any one entry
+// is detected.
+func (h history) checkChains(s *Service, chains map[uuid.UUID][]ledgerEntry) error {
+	for w, chain := range chains {
+		sorted := append([]ledgerEntry(nil), chain...)
+		sort.Slice(sorted, func(i, j int) bool {
+			if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+				return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+			}
+			return sorted[i].ID.String() < sorted[j].ID.String()
+		})
+		prev := genesisHash
+		for _, e := range sorted {
+			if err := s.verifyLink(prev, e); err != nil {
+				return fmt.Errorf("wallet %s: %v", w, err)
+			}
+			prev = e.Hash
+		}
+
+		tampered := sorted[len(sorted)/2]
+		tampered.Amount = tampered.Amount.Add(cents(1))
+		prev = genesisHash
+		if i := len(sorted) / 2; i > 0 {
+			prev = sorted[i-1].Hash
+		}
+		if s.verifyLink(prev, tampered) == nil {
+			return fmt.Errorf("wallet %s: tampered entry %s verified", w, tampered.ID)
+		}
+	}
+	return nil
+}
+
+func copyBalances(m map[uuid.UUID]decimal.Decimal) map[uuid.UUID]decimal.Decimal {
+	out := make(map[uuid.UUID]decimal.Decimal, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func copyHeads(m map[uuid.UUID]string) map[uuid.UUID]string {
+	out := make(map[uuid.UUID]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func TestProperty_JournalInvariants(t *testing.T) {
+	seed := time.Now().UnixNano()
+	cfg := &quick.Config{MaxCount: 300, Rand: rand.New(rand.NewSource(seed))}
+	var failure error
+	err := quick.Check(func(h history) bool {
+		failure = h.replay()
+		return failure == nil
+	}, cfg)
+	if err != nil {
+		t.Fatalf("seed %d: %v", seed, failure)
+	}
+}
@@ -0,0 +1,271 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// ErrChainBroken is returned when drift repair is asked for a wallet whose
+// hash chain does not verify; its entries cannot be trusted to replay.
+var ErrChainBroken = errors.New("ledger hash chain is broken")
+
+// WalletReplay is a wallet's balance rebuilt from its ledger entries alone
+// and compared to the stored ledger balance.
+type WalletReplay struct {
+	WalletID   uuid.UUID       `json:"wallet_id"`
+	Currency   domain.Currency `json:"currency"`
+	Entries    int             `json:"entries"`
+	Replayed   decimal.Decimal `json:"replayed_balance"`
+	Stored     decimal.Decimal `json:"stored_balance"`
+	Drift      decimal.Decimal `json:"drift"` // stored minus replayed
+	ChainValid bool            `json:"chain_valid"`
+	ChainError string          `json:"chain_error,omitempty"`
+	// AdjustmentID is the compensating transaction written by a repair.
+	AdjustmentID *uuid.UUID `json:"adjustment_id,omitempty"`
+}
+
+// Drifted reports whether the stored balance disagrees with the ledger.
+func (w *WalletReplay) Drifted() bool {
+	return !w.Drift.IsZero()
+}
+
+// ReplayOptions selects the wallets to replay and whether to repair drift.
+type ReplayOptions struct {
+	// WalletIDs limits the replay; empty replays every wallet.
+	WalletIDs []uuid.UUID
+	// Repair writes a compensating entry for each drifted wallet whose
+	// chain verifies, so its ledger sums to the stored balance again.
+	Repair bool
+	// Reason is recorded on compensating transactions.
+	Reason string
+}
+
+// ReplayReport is the outcome of ReplayBalances. Wallets lists only the
+// wallets that drifted or whose chain is broken.
+type ReplayReport struct {
+	StartedAt time.Time       `json:"started_at"`
+	Checked   int             `json:"checked"`
+	Drifted   int             `json:"drifted"`
+	Broken    int             `json:"broken_chains"`
+	Repaired  int             `json:"repaired"`
+	Wallets   []*WalletReplay `json:"wallets"`
+}
+
+// Clean reports whether every wallet matched its ledger once any repairs
+// were made.
+func (r *ReplayReport) Clean() bool {
+	return r.Broken == 0 && r.Drifted == r.Repaired
+}
+
+// ReplayBalances rebuilds wallet balances from ledger entries, compares them
+// to the stored ledger balances and, with opts.Repair, writes compensating
+// entries for the drift. Stored balances are never changed: the ledger is
+// brought in line with the balance customers were shown, and the
+// compensating transaction records that it was.
+func (s *Service) ReplayBalances(ctx context.Context, opts ReplayOptions) (*ReplayReport, error) {
+	report := &ReplayReport{StartedAt: time.Now().UTC(), Wallets: []*WalletReplay{}}
+	ids := opts.WalletIDs
+	if len(ids) == 0 {
+		if err := s.db.SelectContext(ctx, &ids, `SELECT id FROM customer_schema.wallets ORDER BY id`); err != nil {
+			return nil, errors.Wrap(err, "failed to list wallets")
+		}
+	}
+	for _, id := range ids {
+		w, err := s.ReplayWallet(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		report.Checked++
+		if !w.ChainValid {
+			report.Broken++
+		}
+		if w.Drifted() {
+			report.Drifted++
+			if opts.Repair && w.ChainValid {
+				if w, err = s.RepairDrift(ctx, id, opts.Reason); err != nil {
+					return nil, err
+				}
+				if w.AdjustmentID != nil {
+					report.Repaired++
+				}
+			}
+		}
+		if w.Drifted() || w.AdjustmentID != nil || !w.ChainValid {
+			report.Wallets = append(report.Wallets, w)
+		}
+	}
+	return report, nil
+}
+
+// ReplayWallet rebuilds one wallet's balance from its ledger entries.
+func (s *Service) ReplayWallet(ctx context.Context, walletID uuid.UUID) (*WalletReplay, error) {
+	return s.replayWallet(ctx, s.db, walletID, false)
+}
+
+// RepairDrift writes a compensating entry that brings the wallet's ledger
+// in line with its stored balance: a credit when the ledger is short, a
+// debit when it is over. The wallet is locked while it is replayed, so the
+// drift cannot change underneath the repair. It returns the replay as it
+// stood before the repair, with AdjustmentID set when an entry was written.
+func (s *Service) RepairDrift(ctx context.Context, walletID uuid.UUID, reason string) (*WalletReplay, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	w, err := s.replayWallet(ctx, tx, walletID, true)
+	if err != nil {
+		return nil, err
+	}
+	if !w.ChainValid {
+		return w, fmt.Errorf("wallet %s: %w: %s", walletID, ErrChainBroken, w.ChainError)
+	}
+	if !w.Drifted() {
+		return w, nil
+	}
+
+	var owner uuid.UUID
+	var available decimal.Decimal
+	if err := tx.QueryRowContext(ctx,
+		`SELECT user_id, available_balance FROM customer_schema.wallets WHERE id = $1`, walletID,
+	).Scan(&owner, &available); err != nil {
+		return nil, errors.Wrap(err, "failed to load wallet")
+	}
+
+	adj := compensatingEntry(w)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if reason == "" {
+		reason = "ledger replay"
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.transactions (
+			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			amount, currency, exchange_rate, converted_amount, converted_currency,
+			fee_amount, net_amount, status, transaction_type, description, metadata,
+			initiated_at, completed_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $3, $4, $4, $5, $6, 1, $5, $6, 0, $5, 'completed', $7, $8, $9, $10, $10, $10, $10)
+	`, adj.TransactionID, "ADJ-"+adj.TransactionID.String(), owner, walletID,
+		adj.Amount, w.Currency, string(domain.TransactionTypeAdjustment),
+		fmt.Sprintf("Ledger replay adjustment: %s", reason),
+		domain.Metadata{"reason": reason, "entry_type": adj.EntryType, "replayed_balance": w.Replayed.String(), "stored_balance": w.Stored.String()},
+		now); err != nil {
+		return nil, errors.Wrap(err, "insert adjustment transaction failed")
+	}
+
+	prevHash, err := s.getLastHash(ctx, tx, walletID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get previous hash")
+	}
+	adj.BalanceAfter = available
+	adj.CreatedAt = now
+	adj.PreviousHash = prevHash
+	adj.Hash = s.calculateHash(prevHash, adj.ID, adj.TransactionID, walletID, adj.EntryType, adj.Amount, adj.Currency, adj.BalanceAfter, adj.CreatedAt)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.ledger_entries (
+			id, transaction_id, wallet_id, entry_type,
+			amount, currency, balance_after, created_at,
+			previous_hash, hash
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, adj.ID, adj.TransactionID, walletID, adj.EntryType, adj.Amount, adj.Currency, adj.BalanceAfter, adj.CreatedAt, adj.PreviousHash, adj.Hash); err != nil {
+		return nil, errors.Wrap(err, "insert adjustment ledger entry failed")
+	}
+	if err := s.ledgerRepo.CreateEntryTx(ctx, tx, adj.TransactionID, "replay_adjustment", adj.Amount, adj.Currency, "completed"); err != nil {
+		return nil, errors.Wrap(err, "failed to create immutable ledger entry")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "transaction commit failed")
+	}
+	w.AdjustmentID = &adj.TransactionID
+	return w, nil
+}
+
+func (s *Service) replayWallet(ctx context.Context, q sqlx.QueryerContext, walletID uuid.UUID, lock bool) (*WalletReplay, error) {
+	query := `SELECT currency, ledger_balance FROM customer_schema.wallets WHERE id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	var currency string
+	var stored decimal.Decimal
+	if err := q.QueryRowxContext(ctx, query, walletID).Scan(&currency, &stored); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrWalletNotFound
+		}
+		return nil, errors.Wrap(err, "failed to load wallet")
+	}
+
+	rows, err := q.QueryxContext(ctx, `
+		SELECT id, transaction_id, wallet_id, entry_type, amount, currency, balance_after, created_at, previous_hash, hash
+		FROM customer_schema.ledger_entries
+		WHERE wallet_id = $1
+		ORDER BY created_at ASC, id ASC
+	`, walletID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query ledger entries")
+	}
+	defer rows.Close()
+	var entries []ledgerEntry
+	for rows.Next() {
+		var e ledgerEntry
+		var cur string
+		if err := rows.Scan(&e.ID, &e.TransactionID, &e.WalletID, &e.EntryType, &e.Amount, &cur, &e.BalanceAfter, &e.CreatedAt, &e.PreviousHash, &e.Hash); err != nil {
+			return nil, errors.Wrap(err, "failed to scan ledger entry")
+		}
+		e.Currency = domain.Currency(cur)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to query ledger entries")
+	}
+	return s.replay(walletID, domain.Currency(currency), stored, entries), nil
+}
+
+// replay sums entries, in chain order, into a balance and verifies the
+// chain along the way.
+func (s *Service) replay(walletID uuid.UUID, currency domain.Currency, stored decimal.Decimal, entries []ledgerEntry) *WalletReplay {
+	w := &WalletReplay{WalletID: walletID, Currency: currency, Entries: len(entries), Replayed: decimal.Zero, Stored: stored, ChainValid: true}
+	prev := genesisHash
+	for _, e := range entries {
+		if w.ChainValid {
+			if err := s.verifyLink(prev, e); err != nil {
+				w.ChainValid, w.ChainError = false, err.Error()
+			}
+		}
+		switch e.EntryType {
+		case "debit":
+			w.Replayed = w.Replayed.Sub(e.Amount)
+		case "credit":
+			w.Replayed = w.Replayed.Add(e.Amount)
+		}
+		prev = e.Hash
+	}
+	w.Drift = stored.Sub(w.Replayed)
+	return w
+}
+
+// compensatingEntry is the entry that cancels w's drift, without its place
+// in the chain.
+func compensatingEntry(w *WalletReplay) ledgerEntry {
+	e := ledgerEntry{
+		ID:            uuid.New(),
+		TransactionID: uuid.New(),
+		WalletID:      w.WalletID,
+		EntryType:     "credit",
+		Amount:        w.Drift,
+		Currency:      w.Currency,
+	}
+	if w.Drift.IsNegative() {
+		e.EntryType, e.Amount = "debit", w.Drift.Neg()
+	}
+	return e
+}
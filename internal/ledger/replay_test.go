@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainFor posts amounts from a funding wallet to w and returns w's entries.
+func chainFor(t *testing.T, s *Service, w uuid.UUID, amounts ...string) []ledgerEntry {
+	funder := uuid.New()
+	var postings []*LedgerPosting
+	for _, a := range amounts {
+		postings = append(postings, payrollPosting(funder, w, a))
+	}
+	balances := map[uuid.UUID]decimal.Decimal{funder: decimal.NewFromInt(1000000), w: decimal.Zero}
+	j, err := s.buildJournal(postings, balances, map[uuid.UUID]string{}, time.Now().UTC().Truncate(time.Microsecond))
+	require.NoError(t, err)
+	var out []ledgerEntry
+	for _, e := range j.entries {
+		if e.WalletID == w {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestReplay_MatchesStoredBalance(t *testing.T) {
+	s, w := &Service{}, uuid.New()
+	entries := chainFor(t, s, w, "100.50", "20")
+
+	r := s.replay(w, "MWK", decimal.RequireFromString("120.50"), entries)
+
+	assert.True(t, r.ChainValid)
+	assert.Equal(t, 2, r.Entries)
+	assert.Equal(t, "120.5", r.Replayed.String())
+	assert.False(t, r.Drifted())
+}
+
+func TestReplay_DriftAndCompensatingEntry(t *testing.T) {
+	s, w := &Service{}, uuid.New()
+	entries := chainFor(t, s, w, "100")
+
+	short := s.replay(w, "MWK", decimal.RequireFromString("150"), entries)
+	require.True(t, short.Drifted())
+	assert.Equal(t, "50", short.Drift.String())
+	adj := compensatingEntry(short)
+	assert.Equal(t, "credit", adj.EntryType)
+	assert.Equal(t, "50", adj.Amount.String())
+
+	over := s.replay(w, "MWK", decimal.RequireFromString("70"), entries)
+	assert.Equal(t, "-30", over.Drift.String())
+	adj = compensatingEntry(over)
+	assert.Equal(t, "debit", adj.EntryType)
+	assert.Equal(t, "30", adj.Amount.String())
+
+	// Chained onto the wallet, the compensating entry clears the drift.
+	last := entries[len(entries)-1]
+	adj.PreviousHash = last.Hash
+	adj.CreatedAt = last.CreatedAt.Add(time.Second)
+	adj.BalanceAfter = decimal.RequireFromString("70")
+	adj.Hash = s.calculateHash(adj.PreviousHash, adj.ID, adj.TransactionID, w, adj.EntryType, adj.Amount, adj.Currency, adj.BalanceAfter, adj.CreatedAt)
+	repaired := s.replay(w, "MWK", decimal.RequireFromString("70"), append(entries, adj))
+	assert.True(t, repaired.ChainValid)
+	assert.False(t, repaired.Drifted())
+}
+
+func TestReplay_BrokenChain(t *testing.T) {
+	s, w := &Service{}, uuid.New()
+	entries := chainFor(t, s, w, "10", "20", "30")
+	entries[1].Amount = decimal.RequireFromString("25")
+
+	r := s.replay(w, "MWK", decimal.RequireFromString("60"), entries)
+
+	assert.False(t, r.ChainValid)
+	assert.Contains(t, r.ChainError, entries[1].ID.String())
+	assert.Equal(t, "65", r.Replayed.String(), "the replay still reports what the entries sum to")
+}
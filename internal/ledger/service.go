@@ -32,14 +32,48 @@ func NewService(db *sqlx.DB, ledgerRepo *postgres.LedgerRepository) *Service {
 	}
 }
 
-// PostTransaction performs double-entry bookkeeping atomically
+// PostTransaction performs double-entry bookkeeping atomically. Postings
+// that carry an IdempotencyKey are applied at most once; see Post.
 func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) error {
+	_, err := s.Post(ctx, posting)
+	return err
+}
+
+// Post performs double-entry bookkeeping atomically and returns a receipt of
+// the entries written. When posting.IdempotencyKey has already been posted,
+// nothing is written and the original receipt is returned with Replayed set;
+// reusing a key for a different posting returns ErrIdempotencyConflict.
+func (s *Service) Post(ctx context.Context, posting *LedgerPosting) (*PostingReceipt, error) {
+	for attempt := 1; ; attempt++ {
+		receipt, err := s.post(ctx, posting)
+		// A concurrent post with the same key makes the loser fail to
+		// serialize; retrying lets it see and replay the winner's receipt.
+		if err != nil && posting.IdempotencyKey != "" && attempt < maxPostAttempts && isSerializationFailure(err) {
+			continue
+		}
+		return receipt, err
+	}
+}
+
+func (s *Service) post(ctx context.Context, posting *LedgerPosting) (*PostingReceipt, error) {
 	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
-		return errors.Wrap(err, "begin transaction failed")
+		return nil, errors.Wrap(err, "begin transaction failed")
 	}
 	defer tx.Rollback()
 
+	// Claim the key first so a retry of a posting that is still in flight
+	// waits for it instead of moving money a second time.
+	if posting.IdempotencyKey != "" {
+		original, err := s.claimIdempotencyKey(ctx, tx, posting)
+		if err != nil {
+			return nil, err
+		}
+		if original != nil {
+			return original, nil
+		}
+	}
+
 	// Lock wallets in deterministic order to prevent deadlocks
 	walletIDs := []uuid.UUID{posting.DebitWalletID, posting.CreditWalletID}
 	if posting.FeeWalletID != nil {
@@ -62,7 +96,7 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 			walletID,
 		).Scan(&balance)
 		if err != nil {
-			return errors.Wrap(err, "wallet lock failed")
+			return nil, errors.Wrap(err, "wallet lock failed")
 		}
 	}
 
@@ -74,15 +108,15 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 			available_balance = available_balance - $1,
 			ledger_balance = ledger_balance - $1,
 			updated_at = NOW()
-		WHERE id = $2 AND available_balance >= $1
+		WHERE id = $2 AND available_balance + CASE WHEN $3 THEN overdraft_limit ELSE 0 END >= $1
 		RETURNING available_balance
-	`, posting.DebitAmount, posting.DebitWalletID).Scan(&debitBalanceAfter)
+	`, posting.DebitAmount, posting.DebitWalletID, posting.AllowOverdraft).Scan(&debitBalanceAfter)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return errors.ErrInsufficientBalance
+			return nil, errors.ErrInsufficientBalance
 		}
-		return errors.Wrap(err, "debit wallet update failed")
+		return nil, errors.Wrap(err, "debit wallet update failed")
 	}
 
 	// Credit receiver wallet
@@ -99,7 +133,7 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 	`, posting.CreditAmount, posting.CreditWalletID).Scan(&creditBalanceAfter)
 
 	if err != nil {
-		return errors.Wrap(err, "credit wallet update failed")
+		return nil, errors.Wrap(err, "credit wallet update failed")
 	}
 
 	// --- Debit Ledger Entry ---
@@ -107,7 +141,7 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 	debitTime := time.Now().UTC().Truncate(time.Microsecond)
 	prevHashDebit, err := s.getLastHash(ctx, tx, posting.DebitWalletID)
 	if err != nil {
-		return errors.Wrap(err, "failed to get debit previous hash")
+		return nil, errors.Wrap(err, "failed to get debit previous hash")
 	}
 	hashDebit := s.calculateHash(prevHashDebit, debitEntryID, posting.TransactionID, posting.DebitWalletID, "debit", posting.DebitAmount, posting.Currency, debitBalanceAfter, debitTime)
 
@@ -120,7 +154,7 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 	`, debitEntryID, posting.TransactionID, posting.DebitWalletID, posting.DebitAmount, posting.Currency, debitBalanceAfter, debitTime, prevHashDebit, hashDebit)
 
 	if err != nil {
-		return errors.Wrap(err, "insert debit ledger entry failed")
+		return nil, errors.Wrap(err, "insert debit ledger entry failed")
 	}
 
 	// --- Credit Ledger Entry ---
@@ -128,7 +162,7 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 	creditTime := time.Now().UTC().Truncate(time.Microsecond)
 	prevHashCredit, err := s.getLastHash(ctx, tx, posting.CreditWalletID)
 	if err != nil {
-		return errors.Wrap(err, "failed to get credit previous hash")
+		return nil, errors.Wrap(err, "failed to get credit previous hash")
 	}
 	hashCredit := s.calculateHash(prevHashCredit, creditEntryID, posting.TransactionID, posting.CreditWalletID, "credit", posting.CreditAmount, posting.ConvertedCurrency, creditBalanceAfter, creditTime)
 
@@ -141,7 +175,15 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 	`, creditEntryID, posting.TransactionID, posting.CreditWalletID, posting.CreditAmount, posting.ConvertedCurrency, creditBalanceAfter, creditTime, prevHashCredit, hashCredit)
 
 	if err != nil {
-		return errors.Wrap(err, "insert credit ledger entry failed")
+		return nil, errors.Wrap(err, "insert credit ledger entry failed")
+	}
+
+	receipt := &PostingReceipt{
+		IdempotencyKey: posting.IdempotencyKey,
+		TransactionID:  posting.TransactionID,
+		DebitEntryID:   debitEntryID,
+		CreditEntryID:  creditEntryID,
+		PostedAt:       debitTime,
 	}
 
 	// --- Fee credit (optional) ---
@@ -158,14 +200,14 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 			RETURNING available_balance
 		`, posting.FeeAmount, *posting.FeeWalletID).Scan(&feeBalanceAfter)
 		if err != nil {
-			return errors.Wrap(err, "fee wallet update failed")
+			return nil, errors.Wrap(err, "fee wallet update failed")
 		}
 
 		feeEntryID := uuid.New()
 		feeTime := time.Now().UTC().Truncate(time.Microsecond)
 		prevHashFee, err := s.getLastHash(ctx, tx, *posting.FeeWalletID)
 		if err != nil {
-			return errors.Wrap(err, "failed to get fee previous hash")
+			return nil, errors.Wrap(err, "failed to get fee previous hash")
 		}
 		hashFee := s.calculateHash(prevHashFee, feeEntryID, posting.TransactionID, *posting.FeeWalletID, "credit", posting.FeeAmount, posting.Currency, feeBalanceAfter, feeTime)
 
@@ -177,10 +219,20 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 			) VALUES ($1, $2, $3, 'credit', $4, $5, $6, $7, $8, $9)
 		`, feeEntryID, posting.TransactionID, *posting.FeeWalletID, posting.FeeAmount, posting.Currency, feeBalanceAfter, feeTime, prevHashFee, hashFee)
 		if err != nil {
-			return errors.Wrap(err, "insert fee ledger entry failed")
+			return nil, errors.Wrap(err, "insert fee ledger entry failed")
 		}
 
 		_ = s.ledgerRepo.CreateEntryTx(ctx, tx, posting.TransactionID, "fee", posting.FeeAmount, posting.Currency, "completed")
+		receipt.FeeEntryID = &feeEntryID
+	}
+
+	// --- General ledger journal ---
+	accounts, err := s.walletAccounts(ctx, tx, walletIDs...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.writeJournals(ctx, tx, postingJournal(posting, accounts)); err != nil {
+		return nil, err
 	}
 
 	// --- Record in Immutable Transaction Ledger ---
@@ -191,13 +243,19 @@ func (s *Service) PostTransaction(ctx context.Context, posting *LedgerPosting) e
 	}
 	err = s.ledgerRepo.CreateEntryTx(ctx, tx, posting.TransactionID, eventType, posting.DebitAmount, posting.Currency, "completed")
 	if err != nil {
-		return errors.Wrap(err, "failed to create immutable ledger entry")
+		return nil, errors.Wrap(err, "failed to create immutable ledger entry")
+	}
+
+	if posting.IdempotencyKey != "" {
+		if err := s.recordReceipt(ctx, tx, receipt); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return errors.Wrap(err, "transaction commit failed")
+		return nil, errors.Wrap(err, "transaction commit failed")
 	}
-	return nil
+	return receipt, nil
 }
 
 type LedgerPosting struct {
@@ -210,10 +268,21 @@ type LedgerPosting struct {
 	Currency          domain.Currency
 	ConvertedCurrency domain.Currency
 	ExchangeRate      decimal.Decimal
-	FeeAmount         decimal.Decimal
-	Reference         string
-	EventType         string
-	Description       string
+	// MidRate is the market mid rate from Currency to ConvertedCurrency.
+	// When set, the general ledger books the difference between the
+	// converted amount at the mid rate and CreditAmount as FX gain or loss.
+	MidRate     decimal.Decimal
+	FeeAmount   decimal.Decimal
+	Reference   string
+	EventType   string
+	Description string
+	// IdempotencyKey makes the posting safe to retry: a key is posted at
+	// most once. Callers derive it from the business event, e.g.
+	// "payment:<transaction id>".
+	IdempotencyKey string
+	// AllowOverdraft lets the debit take the wallet below zero, as far as
+	// its credit facility's overdraft limit.
+	AllowOverdraft bool
 }
 
 func (s *Service) getLastHash(ctx context.Context, tx *sqlx.Tx, walletID uuid.UUID) (string, error) {
@@ -277,13 +346,13 @@ func (s *Service) VerifyChainIntegrity(ctx context.Context, walletID uuid.UUID)
 			return false, errors.Wrap(err, "failed to scan ledger entry")
 		}
 
-		if pHash != prevHash {
-			return false, fmt.Errorf("broken chain at entry %s: expected previous hash %s, got %s", id, prevHash, pHash)
+		e := ledgerEntry{
+			ID: id, TransactionID: txID, WalletID: wID, EntryType: entryType, Amount: amount,
+			Currency: domain.Currency(currency), BalanceAfter: balanceAfter, CreatedAt: createdAt,
+			PreviousHash: pHash, Hash: storedHash,
 		}
-
-		calculatedHash := s.calculateHash(prevHash, id, txID, wID, entryType, amount, domain.Currency(currency), balanceAfter, createdAt)
-		if calculatedHash != storedHash {
-			return false, fmt.Errorf("integrity failure at entry %s: hash mismatch", id)
+		if err := s.verifyLink(prevHash, e); err != nil {
+			return false, err
 		}
 
 		prevHash = storedHash
@@ -292,6 +361,18 @@ func (s *Service) VerifyChainIntegrity(ctx context.Context, walletID uuid.UUID)
 	return true, nil
 }
 
+// verifyLink checks that e follows prevHash in its wallet's chain and that
+// its stored hash matches its contents.
+func (s *Service) verifyLink(prevHash string, e ledgerEntry) error {
+	if e.PreviousHash != prevHash {
+		return fmt.Errorf("broken chain at entry %s: expected previous hash %s, got %s", e.ID, prevHash, e.PreviousHash)
+	}
+	if s.calculateHash(prevHash, e.ID, e.TransactionID, e.WalletID, e.EntryType, e.Amount, e.Currency, e.BalanceAfter, e.CreatedAt) != e.Hash {
+		return fmt.Errorf("integrity failure at entry %s: hash mismatch", e.ID)
+	}
+	return nil
+}
+
 type ChainEntryReport struct {
 	ID                 uuid.UUID       `json:"id"`
 	TransactionID       uuid.UUID       `json:"transaction_id"`
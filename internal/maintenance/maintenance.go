@@ -0,0 +1,220 @@
+// Package maintenance tracks system-wide and per-service maintenance windows.
+// While a service is in maintenance its money-movement endpoints and
+// background submissions are paused; read endpoints stay available.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/pkg/config"
+
+	"github.com/google/uuid"
+)
+
+// ScopeAll puts every service into maintenance.
+const ScopeAll = "all"
+
+// Services that honour maintenance mode.
+const (
+	ServicePayment    = "payment"
+	ServiceWallet     = "wallet"
+	ServiceSettlement = "settlement"
+)
+
+var knownScopes = []string{ScopeAll, ServicePayment, ServiceWallet, ServiceSettlement}
+
+var ErrUnknownScope = errors.New("unknown maintenance scope")
+
+// Window describes an active maintenance period.
+type Window struct {
+	Scope      string     `json:"scope"`
+	Message    string     `json:"message"`
+	RetryAfter int        `json:"retry_after_seconds"`
+	Source     string     `json:"source"` // "config" or "admin"
+	StartedAt  time.Time  `json:"started_at"`
+	StartedBy  *uuid.UUID `json:"started_by,omitempty"`
+}
+
+// Store persists windows toggled at runtime so that every instance sees them.
+type Store interface {
+	// Get returns the window for scope, or nil when there is none.
+	Get(ctx context.Context, scope string) (*Window, error)
+	Set(ctx context.Context, w *Window) error
+	Delete(ctx context.Context, scope string) error
+}
+
+type cachedWindow struct {
+	window    *Window
+	fetchedAt time.Time
+}
+
+// Mode answers whether a service is in maintenance, combining windows forced
+// by configuration with windows toggled by admins.
+type Mode struct {
+	store  Store
+	cfg    config.MaintenanceConfig
+	forced map[string]bool
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedWindow
+}
+
+func NewMode(store Store, cfg config.MaintenanceConfig) *Mode {
+	m := &Mode{
+		store:  store,
+		cfg:    cfg,
+		forced: make(map[string]bool),
+		now:    time.Now,
+		cache:  make(map[string]cachedWindow),
+	}
+	if cfg.Enabled {
+		if len(cfg.Services) == 0 {
+			m.forced[ScopeAll] = true
+		}
+		for _, s := range cfg.Services {
+			m.forced[strings.ToLower(strings.TrimSpace(s))] = true
+		}
+	}
+	return m
+}
+
+// Status returns the window affecting service, or nil when it is available.
+// A service-specific window takes precedence over a system-wide one.
+func (m *Mode) Status(ctx context.Context, service string) *Window {
+	for _, scope := range []string{service, ScopeAll} {
+		if m.forced[scope] {
+			return &Window{
+				Scope:      scope,
+				Message:    m.cfg.Message,
+				RetryAfter: int(m.cfg.RetryAfter.Seconds()),
+				Source:     "config",
+			}
+		}
+		if w := m.lookup(ctx, scope); w != nil {
+			return w
+		}
+	}
+	return nil
+}
+
+// InMaintenance reports whether service is in maintenance, with the message
+// and retry hint to give callers.
+func (m *Mode) InMaintenance(ctx context.Context, service string) (string, time.Duration, bool) {
+	w := m.Status(ctx, service)
+	if w == nil {
+		return "", 0, false
+	}
+	return w.Message, time.Duration(w.RetryAfter) * time.Second, true
+}
+
+// lookup reads a runtime window through a short cache. If the store is
+// unreachable the last known state is kept, so an outage neither starts nor
+// ends maintenance.
+func (m *Mode) lookup(ctx context.Context, scope string) *Window {
+	if m.store == nil {
+		return nil
+	}
+	m.mu.Lock()
+	cached, ok := m.cache[scope]
+	m.mu.Unlock()
+	if ok && m.now().Sub(cached.fetchedAt) < m.cfg.CacheTTL {
+		return cached.window
+	}
+
+	w, err := m.store.Get(ctx, scope)
+	if err != nil {
+		return cached.window
+	}
+	m.mu.Lock()
+	m.cache[scope] = cachedWindow{window: w, fetchedAt: m.now()}
+	m.mu.Unlock()
+	return w
+}
+
+func validScope(scope string) bool {
+	for _, s := range knownScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Enable starts a maintenance window for scope. A zero retryAfter uses the
+// configured default.
+func (m *Mode) Enable(ctx context.Context, scope, message string, retryAfter time.Duration, actor *uuid.UUID) (*Window, error) {
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	if !validScope(scope) {
+		return nil, ErrUnknownScope
+	}
+	if strings.TrimSpace(message) == "" {
+		message = m.cfg.Message
+	}
+	if retryAfter <= 0 {
+		retryAfter = m.cfg.RetryAfter
+	}
+	w := &Window{
+		Scope:      scope,
+		Message:    strings.TrimSpace(message),
+		RetryAfter: int(retryAfter.Seconds()),
+		Source:     "admin",
+		StartedAt:  m.now(),
+		StartedBy:  actor,
+	}
+	if err := m.store.Set(ctx, w); err != nil {
+		return nil, err
+	}
+	m.forget(scope)
+	return w, nil
+}
+
+// Disable ends the runtime window for scope. Windows forced by configuration
+// can only be lifted by redeploying.
+func (m *Mode) Disable(ctx context.Context, scope string) error {
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	if !validScope(scope) {
+		return ErrUnknownScope
+	}
+	if err := m.store.Delete(ctx, scope); err != nil {
+		return err
+	}
+	m.forget(scope)
+	return nil
+}
+
+func (m *Mode) forget(scope string) {
+	m.mu.Lock()
+	delete(m.cache, scope)
+	m.mu.Unlock()
+}
+
+// Windows lists every active window, forced and runtime.
+func (m *Mode) Windows(ctx context.Context) ([]Window, error) {
+	var out []Window
+	for _, scope := range knownScopes {
+		if m.forced[scope] {
+			out = append(out, Window{
+				Scope:      scope,
+				Message:    m.cfg.Message,
+				RetryAfter: int(m.cfg.RetryAfter.Seconds()),
+				Source:     "config",
+			})
+		}
+		if m.store == nil {
+			continue
+		}
+		w, err := m.store.Get(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+		if w != nil {
+			out = append(out, *w)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,144 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kyd/internal/middleware"
+	"kyd/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStore struct {
+	windows map[string]*Window
+	gets    int
+	err     error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{windows: make(map[string]*Window)}
+}
+
+func (s *memoryStore) Get(_ context.Context, scope string) (*Window, error) {
+	s.gets++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.windows[scope], nil
+}
+
+func (s *memoryStore) Set(_ context.Context, w *Window) error {
+	s.windows[w.Scope] = w
+	return nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, scope string) error {
+	delete(s.windows, scope)
+	return nil
+}
+
+func testConfig() config.MaintenanceConfig {
+	return config.MaintenanceConfig{Message: "Back soon", RetryAfter: 10 * time.Minute, CacheTTL: time.Minute}
+}
+
+func TestMode_RuntimeWindows(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	m := NewMode(store, testConfig())
+
+	assert.Nil(t, m.Status(ctx, ServicePayment))
+
+	_, err := m.Enable(ctx, "ledger", "", 0, nil)
+	assert.ErrorIs(t, err, ErrUnknownScope)
+
+	w, err := m.Enable(ctx, ServiceSettlement, "", 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Back soon", w.Message)
+	assert.Equal(t, 600, w.RetryAfter)
+
+	assert.NotNil(t, m.Status(ctx, ServiceSettlement))
+	assert.Nil(t, m.Status(ctx, ServicePayment))
+
+	_, err = m.Enable(ctx, ScopeAll, "Database upgrade", 30*time.Second, nil)
+	assert.NoError(t, err)
+	message, retryAfter, active := m.InMaintenance(ctx, ServicePayment)
+	assert.True(t, active)
+	assert.Equal(t, "Database upgrade", message)
+	assert.Equal(t, 30*time.Second, retryAfter)
+
+	assert.NoError(t, m.Disable(ctx, ScopeAll))
+	assert.NoError(t, m.Disable(ctx, ServiceSettlement))
+	assert.Nil(t, m.Status(ctx, ServicePayment))
+	assert.Nil(t, m.Status(ctx, ServiceSettlement))
+}
+
+func TestMode_CachesAndSurvivesStoreOutage(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	m := NewMode(store, testConfig())
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	_, err := m.Enable(ctx, ServiceWallet, "", 0, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, m.Status(ctx, ServiceWallet))
+	gets := store.gets
+	assert.NotNil(t, m.Status(ctx, ServiceWallet))
+	assert.Equal(t, gets, store.gets, "served from cache")
+
+	// After the cache expires an unreachable store keeps the last known state.
+	now = now.Add(2 * time.Minute)
+	store.err = errors.New("connection refused")
+	assert.NotNil(t, m.Status(ctx, ServiceWallet))
+}
+
+func TestMode_ForcedByConfig(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.Enabled = true
+	cfg.Services = []string{"Payment"}
+	m := NewMode(newMemoryStore(), cfg)
+
+	w := m.Status(ctx, ServicePayment)
+	assert.NotNil(t, w)
+	assert.Equal(t, "config", w.Source)
+	assert.Nil(t, m.Status(ctx, ServiceWallet))
+
+	// Runtime toggles cannot lift a configured window.
+	assert.NoError(t, m.Disable(ctx, ServicePayment))
+	assert.NotNil(t, m.Status(ctx, ServicePayment))
+
+	windows, err := m.Windows(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, windows, 1)
+}
+
+func TestRejectDuringMaintenance(t *testing.T) {
+	m := NewMode(newMemoryStore(), testConfig())
+	handler := middleware.RejectDuringMaintenance(m, ServicePayment)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/payments", nil))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	_, err := m.Enable(context.Background(), ScopeAll, "Upgrading", 2*time.Minute, nil)
+	assert.NoError(t, err)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/payments", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "120", rec.Header().Get("Retry-After"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "maintenance", body["code"])
+	assert.Equal(t, "Upgrading", body["error"])
+	assert.Equal(t, float64(120), body["retry_after"])
+}
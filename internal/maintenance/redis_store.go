@@ -0,0 +1,47 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "maintenance:"
+
+// RedisStore keeps runtime maintenance windows in Redis, shared by all services.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, scope string) (*Window, error) {
+	data, err := s.client.Get(ctx, redisKeyPrefix+scope).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var w Window
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, w *Window) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisKeyPrefix+w.Scope, data, 0).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, scope string) error {
+	return s.client.Del(ctx, redisKeyPrefix+scope).Err()
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ctxAPIKeyIDKey       contextKey = "api_key_id"
+	ctxAPIEnvironmentKey contextKey = "api_environment"
+
+	// apiKeyErrorBody bounds how much of a failed response is kept as a
+	// sample.
+	apiKeyErrorBody = 512
+)
+
+// APIKeyAuthenticator resolves the API keys presented in the X-API-Key
+// header and records the calls made with them. Satisfied by
+// auth.APIKeyService.
+type APIKeyAuthenticator interface {
+	ValidateKey(ctx context.Context, rawKey string) (*domain.APIKey, error)
+	RecordCall(call domain.APIKeyCall)
+}
+
+// UserTypeLookup returns the type of a user account.
+type UserTypeLookup interface {
+	UserType(ctx context.Context, id uuid.UUID) (string, error)
+}
+
+// WithAPIKeys lets requests authenticate with an API key in the X-API-Key
+// header instead of a bearer token. A key acts as the customer account it
+// was issued to, with that account's type from owners; platform keys and
+// keys of admin accounts are not accepted.
+func (m *AuthMiddleware) WithAPIKeys(keys APIKeyAuthenticator, owners UserTypeLookup) *AuthMiddleware {
+	m.apiKeys = keys
+	m.keyOwners = owners
+	return m
+}
+
+// authenticateKey serves r as the owner of rawKey and records the call.
+func (m *AuthMiddleware) authenticateKey(w http.ResponseWriter, r *http.Request, next http.Handler, rawKey string) {
+	key, err := m.apiKeys.ValidateKey(r.Context(), rawKey)
+	switch {
+	case errors.Is(err, pkgerrors.ErrInvalidAPIKey):
+		respondJSONError(w, http.StatusUnauthorized, "Invalid API key")
+		return
+	case errors.Is(err, pkgerrors.ErrAPIKeyRefused):
+		respondJSONError(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		respondJSONError(w, http.StatusServiceUnavailable, "Authentication service unavailable")
+		return
+	}
+	if key.OwnerID == nil {
+		respondJSONError(w, http.StatusUnauthorized, "API key is not issued to an account")
+		return
+	}
+	if m.statusChecker != nil {
+		active, err := m.statusChecker.IsUserActive(r.Context(), *key.OwnerID)
+		if err != nil {
+			respondJSONError(w, http.StatusInternalServerError, "Failed to verify account status")
+			return
+		}
+		if !active {
+			respondJSONError(w, http.StatusForbidden, "Account is blocked")
+			return
+		}
+	}
+
+	userType, err := m.keyOwners.UserType(r.Context(), *key.OwnerID)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, "Failed to verify account status")
+		return
+	}
+	if userType == string(domain.UserTypeAdmin) {
+		respondJSONError(w, http.StatusForbidden, "API keys cannot act for admin accounts")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), ctxUserIDKey, *key.OwnerID)
+	ctx = context.WithValue(ctx, ctxUserTypeKey, userType)
+	ctx = context.WithValue(ctx, ctxAPIKeyIDKey, key.ID)
+	ctx = context.WithValue(ctx, ctxAPIEnvironmentKey, key.Environment)
+
+	rec := &callRecorder{ResponseWriter: w, status: http.StatusOK}
+	start := time.Now()
+	next.ServeHTTP(rec, r.WithContext(ctx))
+
+	call := domain.APIKeyCall{
+		KeyID:     key.ID,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    rec.status,
+		Latency:   time.Since(start),
+		RequestID: w.Header().Get("X-Request-ID"),
+		At:        start,
+	}
+	if call.Failed() {
+		call.ResponseBody = rec.body.String()
+	}
+	m.apiKeys.RecordCall(call)
+}
+
+// callRecorder captures a response's status and the start of its body.
+type callRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *callRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *callRecorder) Write(b []byte) (int, error) {
+	if room := apiKeyErrorBody - w.body.Len(); room > 0 {
+		if len(b) < room {
+			room = len(b)
+		}
+		w.body.Write(b[:room])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// APIKeyIDFromContext returns the API key a request authenticated with.
+func APIKeyIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(ctxAPIKeyIDKey).(uuid.UUID)
+	return id, ok
+}
+
+// APIEnvironmentFromContext returns the environment of the API key a
+// request authenticated with, or empty for bearer tokens.
+func APIEnvironmentFromContext(ctx context.Context) domain.APIEnvironment {
+	env, _ := ctx.Value(ctxAPIEnvironmentKey).(domain.APIEnvironment)
+	return env
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKeys struct {
+	keys  map[string]*domain.APIKey
+	err   error
+	calls []domain.APIKeyCall
+}
+
+func (f *fakeKeys) ValidateKey(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	k, ok := f.keys[rawKey]
+	if !ok {
+		return nil, pkgerrors.ErrInvalidAPIKey
+	}
+	return k, nil
+}
+
+func (f *fakeKeys) RecordCall(call domain.APIKeyCall) {
+	f.calls = append(f.calls, call)
+}
+
+type fakeOwners map[uuid.UUID]string
+
+func (f fakeOwners) UserType(ctx context.Context, id uuid.UUID) (string, error) {
+	return f[id], nil
+}
+
+func TestAuthenticate_APIKey(t *testing.T) {
+	owner, admin := uuid.New(), uuid.New()
+	key := &domain.APIKey{ID: uuid.New(), OwnerID: &owner, Environment: domain.APIEnvironmentSandbox}
+	keys := &fakeKeys{keys: map[string]*domain.APIKey{
+		"kyd_test_good":   key,
+		"kyd_live_admin":  {ID: uuid.New(), OwnerID: &admin},
+		"kyd_live_nobody": {ID: uuid.New()},
+	}}
+	m := NewAuthMiddleware("secret", nil).WithAPIKeys(keys, fakeOwners{
+		owner: string(domain.UserTypeMerchant),
+		admin: string(domain.UserTypeAdmin),
+	})
+
+	var gotUser uuid.UUID
+	var gotType string
+	var gotEnv domain.APIEnvironment
+	h := m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = UserIDFromContext(r.Context())
+		gotType, _ = UserTypeFromContext(r.Context())
+		gotEnv = APIEnvironmentFromContext(r.Context())
+		w.Header().Set("X-Request-ID", "req-1")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"error":"amount must be positive"}`)
+	}))
+
+	serve := func(rawKey string) int {
+		req := httptest.NewRequest("POST", "/api/v1/payments/initiate", nil)
+		req.Header.Set("X-API-Key", rawKey)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("kyd_test_good"))
+	assert.Equal(t, owner, gotUser)
+	assert.Equal(t, string(domain.UserTypeMerchant), gotType)
+	assert.Equal(t, domain.APIEnvironmentSandbox, gotEnv)
+	require.Len(t, keys.calls, 1)
+	assert.Equal(t, key.ID, keys.calls[0].KeyID)
+	assert.Equal(t, http.StatusUnprocessableEntity, keys.calls[0].Status)
+	assert.Equal(t, "req-1", keys.calls[0].RequestID)
+	assert.Equal(t, `{"error":"amount must be positive"}`, keys.calls[0].ResponseBody)
+
+	assert.Equal(t, http.StatusUnauthorized, serve("kyd_live_unknown"))
+	assert.Equal(t, http.StatusUnauthorized, serve("kyd_live_nobody"))
+	assert.Equal(t, http.StatusForbidden, serve("kyd_live_admin"))
+	assert.Len(t, keys.calls, 1, "refused keys are not recorded")
+
+	keys.err = fmt.Errorf("%w: sandbox environment is switched off", pkgerrors.ErrAPIKeyRefused)
+	assert.Equal(t, http.StatusForbidden, serve("kyd_test_good"))
+}
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"strings"
 
+	"kyd/internal/domain"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -16,9 +18,11 @@ import (
 type contextKey string
 
 const (
-	ctxUserIDKey   contextKey = "user_id"
-	ctxEmailKey    contextKey = "email"
-	ctxUserTypeKey contextKey = "user_type"
+	ctxUserIDKey    contextKey = "user_id"
+	ctxEmailKey     contextKey = "email"
+	ctxUserTypeKey  contextKey = "user_type"
+	ctxAdminRoleKey contextKey = "admin_role"
+	ctxDeviceIDKey  contextKey = "device_id"
 )
 
 // TokenBlacklist defines the interface for checking revoked tokens.
@@ -36,6 +40,8 @@ type AuthMiddleware struct {
 	jwtSecrets    []string
 	blacklist     TokenBlacklist
 	statusChecker UserStatusChecker
+	apiKeys       APIKeyAuthenticator
+	keyOwners     UserTypeLookup
 }
 
 // NewAuthMiddleware constructs an AuthMiddleware with the given secret and optional blacklist.
@@ -51,9 +57,15 @@ func NewAuthMiddlewareWithUserStatus(secret string, blacklist TokenBlacklist, st
 	}
 }
 
-// Authenticate enforces bearer auth and populates user details on the request context.
+// Authenticate enforces bearer auth, or API key auth when enabled, and
+// populates user details on the request context.
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rawKey := r.Header.Get("X-API-Key"); rawKey != "" && m.apiKeys != nil {
+			m.authenticateKey(w, r, next, rawKey)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if strings.TrimSpace(authHeader) == "" {
 			respondJSONError(w, http.StatusUnauthorized, "Authorization header required")
@@ -111,6 +123,26 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Tokens issued on a device the user has since revoked
+		deviceID := uuid.Nil
+		if raw, ok := claims["device_id"].(string); ok {
+			if deviceID, err = uuid.Parse(raw); err != nil {
+				respondJSONError(w, http.StatusUnauthorized, "Invalid token claims")
+				return
+			}
+			if m.blacklist != nil {
+				revoked, err := m.blacklist.IsBlacklisted(r.Context(), domain.DeviceRevocationKey(deviceID))
+				if err != nil {
+					respondJSONError(w, http.StatusServiceUnavailable, "Authentication service unavailable")
+					return
+				}
+				if revoked {
+					respondJSONError(w, http.StatusUnauthorized, "Token revoked")
+					return
+				}
+			}
+		}
+
 		userID, err := uuid.Parse(userIDStr)
 		if err != nil {
 			respondJSONError(w, http.StatusUnauthorized, "Invalid user ID format")
@@ -136,6 +168,12 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 		if utRaw, ok := claims["user_type"]; ok {
 			ctx = context.WithValue(ctx, ctxUserTypeKey, fmt.Sprintf("%v", utRaw))
 		}
+		if role, ok := claims["admin_role"].(string); ok {
+			ctx = context.WithValue(ctx, ctxAdminRoleKey, role)
+		}
+		if deviceID != uuid.Nil {
+			ctx = context.WithValue(ctx, ctxDeviceIDKey, deviceID)
+		}
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -147,12 +185,26 @@ func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
 	return userID, ok
 }
 
+// DeviceIDFromContext extracts the device the token was issued on. It is not
+// set for tokens issued without one.
+func DeviceIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(ctxDeviceIDKey).(uuid.UUID)
+	return id, ok
+}
+
 // UserTypeFromContext extracts the user type from the request context.
 func UserTypeFromContext(ctx context.Context) (string, bool) {
 	ut, ok := ctx.Value(ctxUserTypeKey).(string)
 	return ut, ok
 }
 
+// AdminRoleFromContext extracts the admin role from the request context. It is
+// empty for non-admins and for admins without a role.
+func AdminRoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(ctxAdminRoleKey).(string)
+	return role
+}
+
 func respondJSONError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -171,7 +223,7 @@ func CORS(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Correlation-ID, X-CSRF-Token, Idempotency-Key, X-Device-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Correlation-ID, X-CSRF-Token, Idempotency-Key, X-Device-ID, X-API-Key")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type revokedSet map[string]bool
+
+func (s revokedSet) IsBlacklisted(ctx context.Context, token string) (bool, error) {
+	return s[token], nil
+}
+
+func TestAuthenticate_RevokedDevice(t *testing.T) {
+	userID, laptop, phone := uuid.New(), uuid.New(), uuid.New()
+	m := NewAuthMiddleware("secret", revokedSet{domain.DeviceRevocationKey(laptop): true})
+
+	var gotDevice uuid.UUID
+	h := m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotDevice, _ = DeviceIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(device uuid.UUID) int {
+		claims := jwt.MapClaims{"user_id": userID.String(), "exp": time.Now().Add(time.Hour).Unix()}
+		if device != uuid.Nil {
+			claims["device_id"] = device.String()
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		req := httptest.NewRequest("GET", "/api/v1/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(laptop))
+	assert.Equal(t, http.StatusOK, serve(phone))
+	assert.Equal(t, phone, gotDevice)
+	assert.Equal(t, http.StatusOK, serve(uuid.Nil), "tokens issued without a device")
+}
@@ -2,31 +2,97 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"time"
 
+	"kyd/pkg/metrics"
+
 	"github.com/redis/go-redis/v9"
 )
 
+// TokenRevocationStore keeps revoked tokens where Redis cannot, so token
+// checks can refuse them while it is down. Keys are SHA-256 hashes of what
+// Blacklist was given.
+type TokenRevocationStore interface {
+	Revoke(ctx context.Context, keyHash string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, keyHash string) (bool, error)
+}
+
 // RedisTokenBlacklist implements TokenBlacklist using Redis.
 type RedisTokenBlacklist struct {
-	client *redis.Client
+	client  *redis.Client
+	failure FailureMode
+	store   TokenRevocationStore
 }
 
-// NewRedisTokenBlacklist creates a new RedisTokenBlacklist.
+// NewRedisTokenBlacklist creates a new RedisTokenBlacklist. Checks fail
+// while Redis is down, so tokens are refused, unless WithFailureMode says
+// otherwise.
 func NewRedisTokenBlacklist(client *redis.Client) *RedisTokenBlacklist {
-	return &RedisTokenBlacklist{client: client}
+	return &RedisTokenBlacklist{client: client, failure: FailClosed}
+}
+
+// WithFailureMode sets what checks report while Redis is down: with
+// FailOpen no token is revoked, so tokens revoked before the outage work
+// until it ends or they expire. With FailLocal and a store, checks go to
+// the store and only revoked tokens are refused.
+func (b *RedisTokenBlacklist) WithFailureMode(mode FailureMode) *RedisTokenBlacklist {
+	b.failure = mode
+	return b
+}
+
+// WithFallback records every revocation in store as well as Redis. Checks
+// also look in store after a Redis miss, so tokens revoked during an
+// outage stay revoked once it is over.
+func (b *RedisTokenBlacklist) WithFallback(store TokenRevocationStore) *RedisTokenBlacklist {
+	b.store = store
+	return b
 }
 
-// Blacklist adds a token to the blacklist with an expiration.
+// Blacklist adds a token to the blacklist with an expiration. With a store
+// the token is revoked once it is stored, even if Redis is down.
 func (b *RedisTokenBlacklist) Blacklist(ctx context.Context, token string, expiration time.Duration) error {
-	return b.client.Set(ctx, "blacklist:"+token, "revoked", expiration).Err()
+	if b.store == nil {
+		return b.client.Set(ctx, "blacklist:"+token, "revoked", expiration).Err()
+	}
+	if err := b.store.Revoke(ctx, revocationHash(token), time.Now().Add(expiration)); err != nil {
+		return err
+	}
+	if err := b.client.Set(ctx, "blacklist:"+token, "revoked", expiration).Err(); err != nil {
+		metrics.ObserveDegraded("token_revocation", "local")
+	}
+	return nil
 }
 
 // IsBlacklisted checks if a token is in the blacklist.
 func (b *RedisTokenBlacklist) IsBlacklisted(ctx context.Context, token string) (bool, error) {
 	exists, err := b.client.Exists(ctx, "blacklist:"+token).Result()
-	if err != nil {
-		return false, err
+	if err == nil {
+		if exists > 0 || b.store == nil {
+			return exists > 0, nil
+		}
+		return b.store.IsRevoked(ctx, revocationHash(token))
 	}
-	return exists > 0, nil
+	switch {
+	case b.failure == FailOpen:
+		metrics.ObserveDegraded("token_revocation", "allowed")
+		return false, nil
+	case b.failure == FailLocal && b.store != nil:
+		revoked, serr := b.store.IsRevoked(ctx, revocationHash(token))
+		if serr != nil {
+			metrics.ObserveDegraded("token_revocation", "rejected")
+			return false, errors.Join(err, serr)
+		}
+		metrics.ObserveDegraded("token_revocation", "local")
+		return revoked, nil
+	}
+	metrics.ObserveDegraded("token_revocation", "rejected")
+	return false, err
+}
+
+func revocationHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -6,13 +6,16 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type correlationKey string
 
 const ctxRequestIDKey correlationKey = "request_id"
 
-// CorrelationID ensures every request has an X-Request-ID for tracing.
+// CorrelationID ensures every request has an X-Request-ID for tracing, and
+// records it on the request's span so logs and traces can be joined.
 func CorrelationID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqID := r.Header.Get("X-Request-ID")
@@ -20,6 +23,7 @@ func CorrelationID(next http.Handler) http.Handler {
 			reqID = uuid.NewString()
 		}
 
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request.id", reqID))
 		ctx := context.WithValue(r.Context(), ctxRequestIDKey, reqID)
 		w.Header().Set("X-Request-ID", reqID)
 
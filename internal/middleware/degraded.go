@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"kyd/pkg/logger"
+	"kyd/pkg/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FailureMode is what a Redis-backed middleware does with requests while
+// Redis is down.
+type FailureMode string
+
+const (
+	// FailOpen lets requests through unchecked.
+	FailOpen FailureMode = "open"
+	// FailClosed refuses requests with 503.
+	FailClosed FailureMode = "closed"
+	// FailLocal checks requests without Redis: rate limits in memory per
+	// process, idempotency keys and token revocations in the database
+	// where a store is set.
+	FailLocal FailureMode = "local"
+)
+
+// ErrRedisDegraded fails the Redis commands a RedisGuard skips because
+// Redis is down.
+var ErrRedisDegraded = errors.New("redis is unavailable, running degraded")
+
+// RedisAlerter is told when a service loses Redis and when it is back. It
+// is called on its own goroutine.
+type RedisAlerter interface {
+	RedisDown(service string, cause error)
+	RedisRecovered(service string, downFor time.Duration)
+}
+
+// RedisGuard is a Redis client hook that takes a service off Redis once it
+// stops answering: commands then fail at once with ErrRedisDegraded rather
+// than each waiting out the timeouts, and the middleware runs in its
+// failure mode. One command every retry period goes through as a probe;
+// the first to succeed ends the outage.
+type RedisGuard struct {
+	service string
+	retry   time.Duration
+	logger  logger.Logger
+	now     func() time.Time
+
+	mu        sync.Mutex
+	alerter   RedisAlerter
+	downSince time.Time // zero while Redis answers
+	nextProbe time.Time
+}
+
+// NewRedisGuard guards the Redis client of service; add it with
+// client.AddHook.
+func NewRedisGuard(service string, retryAfter time.Duration, log logger.Logger) *RedisGuard {
+	return &RedisGuard{service: service, retry: retryAfter, logger: log, now: time.Now}
+}
+
+// WithAlerts reports outages to a beyond the log and the
+// kyd_redis_degraded gauge.
+func (g *RedisGuard) WithAlerts(a RedisAlerter) *RedisGuard {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.alerter = a
+	return g
+}
+
+// Degraded reports whether the service is running without Redis, and
+// since when.
+func (g *RedisGuard) Degraded() (bool, time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !g.downSince.IsZero(), g.downSince
+}
+
+func (g *RedisGuard) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (g *RedisGuard) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		admitted, probe := g.admit()
+		if !admitted {
+			cmd.SetErr(ErrRedisDegraded)
+			return ErrRedisDegraded
+		}
+		err := next(ctx, cmd)
+		g.observe(err, probe)
+		return err
+	}
+}
+
+func (g *RedisGuard) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		admitted, probe := g.admit()
+		if !admitted {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrRedisDegraded)
+			}
+			return ErrRedisDegraded
+		}
+		err := next(ctx, cmds)
+		g.observe(err, probe)
+		return err
+	}
+}
+
+// admit reports whether a command may go to Redis: always while it
+// answers, and while it is down only as the probe due every retry period.
+func (g *RedisGuard) admit() (admitted, probe bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.downSince.IsZero() {
+		return true, false
+	}
+	now := g.now()
+	if now.Before(g.nextProbe) {
+		return false, false
+	}
+	g.nextProbe = now.Add(g.retry)
+	return true, true
+}
+
+// observe records the outcome of a command. Only a probe ends an outage:
+// commands sent before it began may still be returning.
+func (g *RedisGuard) observe(err error, probe bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if !unavailable(err) {
+		if !probe || g.downSince.IsZero() {
+			return
+		}
+		downFor := now.Sub(g.downSince)
+		g.downSince = time.Time{}
+		metrics.SetRedisDegraded(g.service, false)
+		g.logger.Warn("Redis is back, leaving degraded mode", map[string]interface{}{
+			"down_for": downFor.String(),
+		})
+		if a := g.alerter; a != nil {
+			go a.RedisRecovered(g.service, downFor)
+		}
+		return
+	}
+	g.nextProbe = now.Add(g.retry)
+	if !g.downSince.IsZero() {
+		return
+	}
+	g.downSince = now
+	metrics.SetRedisDegraded(g.service, true)
+	g.logger.Error("Redis is unavailable, running degraded", map[string]interface{}{
+		"error":       err.Error(),
+		"retry_after": g.retry.String(),
+	})
+	if a := g.alerter; a != nil {
+		go a.RedisDown(g.service, err)
+	}
+}
+
+// unavailable reports whether err means Redis could not be reached, rather
+// than a reply such as a missing key or an unknown script.
+func unavailable(err error) bool {
+	var reply redis.Error
+	return err != nil && !errors.Is(err, context.Canceled) && !errors.As(err, &reply)
+}
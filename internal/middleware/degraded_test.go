@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"kyd/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingAlerter struct {
+	mu   sync.Mutex
+	down []string
+	up   []string
+}
+
+func (a *recordingAlerter) RedisDown(service string, cause error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.down = append(a.down, service)
+}
+
+func (a *recordingAlerter) RedisRecovered(service string, downFor time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.up = append(a.up, service)
+}
+
+func (a *recordingAlerter) counts() (int, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.down), len(a.up)
+}
+
+// deadRedis returns a client on a port nothing listens on, failing fast.
+func deadRedis(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 200 * time.Millisecond})
+	client.AddHook(NewRedisGuard("test", time.Minute, logger.NewNop()))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRedisGuard(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	alerts := &recordingAlerter{}
+	g := NewRedisGuard("payment", 5*time.Second, logger.NewNop()).WithAlerts(alerts)
+	g.now = func() time.Time { return now }
+
+	calls := 0
+	var reply error
+	run := g.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+		calls++
+		return reply
+	})
+	do := func() error { return run(context.Background(), redis.NewStatusCmd(context.Background(), "ping")) }
+
+	reply = redis.Nil
+	require.ErrorIs(t, do(), redis.Nil)
+	degraded, _ := g.Degraded()
+	assert.False(t, degraded, "a missing key is an answer")
+
+	reply = errors.New("dial tcp: connection refused")
+	require.Error(t, do())
+	degraded, since := g.Degraded()
+	require.True(t, degraded)
+	assert.Equal(t, now, since)
+
+	cmd := redis.NewStatusCmd(context.Background(), "ping")
+	assert.ErrorIs(t, run(context.Background(), cmd), ErrRedisDegraded)
+	assert.ErrorIs(t, cmd.Err(), ErrRedisDegraded, "skipped commands carry the error")
+	assert.Equal(t, 2, calls, "not sent while down")
+
+	now = now.Add(5 * time.Second)
+	require.Error(t, do(), "the probe fails")
+	assert.Equal(t, 3, calls)
+	assert.ErrorIs(t, do(), ErrRedisDegraded, "one probe per period")
+
+	now = now.Add(5 * time.Second)
+	reply = nil
+	require.NoError(t, do())
+	degraded, _ = g.Degraded()
+	assert.False(t, degraded)
+
+	assert.Eventually(t, func() bool {
+		down, up := alerts.counts()
+		return down == 1 && up == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRateLimiterWithoutRedis(t *testing.T) {
+	client := deadRedis(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	serve := func(rl *RateLimiter) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		rl.Limit(ok).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	open := NewRateLimiter(client, 1, time.Minute).WithName("open")
+	assert.Equal(t, http.StatusOK, serve(open))
+	assert.Equal(t, http.StatusOK, serve(open))
+
+	closed := NewRateLimiter(client, 1, time.Minute).WithName("closed").WithFailureMode(FailClosed)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(closed))
+
+	local := NewRateLimiter(client, 2, time.Minute).WithName("local").WithFailureMode(FailLocal)
+	assert.Equal(t, http.StatusOK, serve(local))
+	assert.Equal(t, http.StatusOK, serve(local))
+	assert.Equal(t, http.StatusTooManyRequests, serve(local), "counted in memory")
+
+	q, err := local.Quota(context.Background(), "10.0.0.1", uuid.Nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, q.Remaining)
+	_, err = closed.Quota(context.Background(), "10.0.0.1", uuid.Nil)
+	assert.Error(t, err)
+}
+
+func TestLocalWindow(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	l := newLocalWindow(2, time.Minute)
+
+	remaining, _, ok := l.allow("a", start)
+	assert.True(t, ok)
+	assert.Equal(t, 1, remaining)
+	_, _, ok = l.allow("a", start.Add(10*time.Second))
+	assert.True(t, ok)
+	_, reset, ok := l.allow("a", start.Add(20*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, start.Add(time.Minute), reset)
+	_, _, ok = l.allow("b", start.Add(20*time.Second))
+	assert.True(t, ok, "clients are counted apart")
+
+	_, _, ok = l.allow("a", start.Add(61*time.Second))
+	assert.True(t, ok, "the first request left the window")
+
+	l.allow("c", start.Add(5*time.Minute))
+	assert.NotContains(t, l.hits, "a", "idle clients are swept")
+	assert.Contains(t, l.hits, "c")
+}
+
+// memIdempotency keeps keys as the database does.
+type memIdempotency struct {
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func (m *memIdempotency) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[key]; ok {
+		return false, nil
+	}
+	m.keys[key] = nil
+	return true, nil
+}
+
+func (m *memIdempotency) Lookup(ctx context.Context, key string) (bool, []byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	response, ok := m.keys[key]
+	return ok, response, nil
+}
+
+func (m *memIdempotency) Complete(ctx context.Context, key string, response []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = response
+	return nil
+}
+
+func (m *memIdempotency) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.keys[key] == nil {
+		delete(m.keys, key)
+	}
+	return nil
+}
+
+func TestIdempotencyWithoutRedis(t *testing.T) {
+	client := deadRedis(t)
+	store := &memIdempotency{keys: map[string][]byte{}}
+	runs := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		if r.Header.Get("X-Fail") != "" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})
+	serve := func(mw *IdempotencyMiddleware, path, key string, fail bool) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Idempotency-Key", key)
+		if fail {
+			req.Header.Set("X-Fail", "1")
+		}
+		mw.Require(h).ServeHTTP(rec, req)
+		return rec
+	}
+
+	closed := NewIdempotencyMiddleware(client, time.Hour)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(closed, "/api/v1/payments", "k1", false).Code)
+	assert.Equal(t, 0, runs)
+
+	open := NewIdempotencyMiddleware(client, time.Hour).WithFailureMode(FailOpen)
+	assert.Equal(t, http.StatusCreated, serve(open, "/api/v1/profile", "k1", false).Code)
+	assert.Equal(t, 1, runs)
+
+	local := NewIdempotencyMiddleware(client, time.Hour).WithFailureMode(FailLocal).
+		WithFallback(store, "/api/v1/payments")
+	assert.Equal(t, http.StatusServiceUnavailable, serve(local, "/api/v1/profile", "k2", false).Code, "no fallback")
+
+	first := serve(local, "/api/v1/payments", "k3", false)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	retry := serve(local, "/api/v1/payments", "k3", false)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, `{"id":1}`, retry.Body.String(), "replayed")
+	assert.Equal(t, 2, runs, "run once")
+
+	assert.Equal(t, http.StatusBadGateway, serve(local, "/api/v1/payments", "k4", true).Code)
+	assert.NotContains(t, store.keys, "POST:k4", "nothing to replay, so the key is freed")
+	store.keys["POST:k5"] = nil
+	assert.Equal(t, http.StatusConflict, serve(local, "/api/v1/payments", "k5", false).Code, "still running")
+}
+
+// memRevocations keeps revoked tokens as the database does.
+type memRevocations struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func (m *memRevocations) Revoke(ctx context.Context, keyHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revoked[keyHash] = expiresAt
+	return nil
+}
+
+func (m *memRevocations) IsRevoked(ctx context.Context, keyHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiresAt, ok := m.revoked[keyHash]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func TestTokenChecksWithoutRedis(t *testing.T) {
+	const secret = "test-secret"
+	client := deadRedis(t)
+	store := &memRevocations{revoked: map[string]time.Time{}}
+	payments := 0
+	pay := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payments++
+		w.WriteHeader(http.StatusCreated)
+	})
+	token := func() string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id":   uuid.NewString(),
+			"device_id": uuid.NewString(),
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return s
+	}
+	serve := func(b *RedisTokenBlacklist, token string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/payments/initiate", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		NewAuthMiddleware(secret, b).Authenticate(pay).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	local := NewRedisTokenBlacklist(client).WithFailureMode(FailLocal).WithFallback(store)
+	assert.Equal(t, http.StatusCreated, serve(local, token()), "payments go through")
+	assert.Equal(t, 1, payments)
+
+	revoked := token()
+	require.NoError(t, local.Blacklist(context.Background(), revoked, time.Hour), "stored while Redis is down")
+	assert.Equal(t, http.StatusUnauthorized, serve(local, revoked))
+	assert.NotContains(t, store.revoked, revoked, "only its hash is stored")
+
+	closed := NewRedisTokenBlacklist(client).WithFallback(store)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(closed, token()))
+	assert.Equal(t, 1, payments)
+}
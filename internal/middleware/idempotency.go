@@ -2,28 +2,76 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
 	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/metrics"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// IdempotencyStore keeps idempotency keys where Redis cannot, for the
+// routes that must stay idempotent while it is down. Keys are
+// "<method>:<Idempotency-Key>"; responses are opaque.
+type IdempotencyStore interface {
+	// Claim takes key for ttl for a request about to run, reporting false
+	// when it is already taken.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Lookup reports whether key is taken and the response saved under it,
+	// nil while its request is still running.
+	Lookup(ctx context.Context, key string) (bool, []byte, error)
+	// Complete saves the response of the request holding key.
+	Complete(ctx context.Context, key string, response []byte) error
+	// Release frees key when its request left nothing to replay.
+	Release(ctx context.Context, key string) error
+}
+
 // IdempotencyMiddleware enforces Idempotency-Key usage for unsafe methods.
 type IdempotencyMiddleware struct {
-	cache *redis.Client
-	ttl   time.Duration
+	cache     *redis.Client
+	ttl       time.Duration
+	failure   FailureMode
+	store     IdempotencyStore
+	fallbacks map[string]bool
 }
 
 // NewIdempotencyMiddleware constructs an IdempotencyMiddleware with a TTL.
+// While Redis is down it refuses requests unless WithFailureMode says
+// otherwise.
 func NewIdempotencyMiddleware(cache *redis.Client, ttl time.Duration) *IdempotencyMiddleware {
 	return &IdempotencyMiddleware{
-		cache: cache,
-		ttl:   ttl,
+		cache:   cache,
+		ttl:     ttl,
+		failure: FailClosed,
+	}
+}
+
+// WithFailureMode sets what happens to requests while Redis is down. With
+// FailLocal requests to the fallback paths keep their keys in the store
+// and all others are refused.
+func (m *IdempotencyMiddleware) WithFailureMode(mode FailureMode) *IdempotencyMiddleware {
+	m.failure = mode
+	return m
+}
+
+// WithFallback keeps the keys of requests to paths in store while Redis is
+// down. Those routes also check store after a Redis miss, so keys taken
+// during an outage are honoured once it is over.
+func (m *IdempotencyMiddleware) WithFallback(store IdempotencyStore, paths ...string) *IdempotencyMiddleware {
+	m.store = store
+	m.fallbacks = make(map[string]bool, len(paths))
+	for _, p := range paths {
+		m.fallbacks[p] = true
 	}
+	return m
+}
+
+func (m *IdempotencyMiddleware) hasFallback(r *http.Request) bool {
+	return m.store != nil && m.fallbacks[r.URL.Path]
 }
 
 // Require blocks duplicate POST/PUT/PATCH/DELETE requests with the same key.
@@ -48,7 +96,12 @@ func (m *IdempotencyMiddleware) Require(next http.Handler) http.Handler {
 		lockKey := fmt.Sprintf("idempotency:lock:%s:%s", r.Method, key)
 
 		// Fast path: cached response exists
-		if handled := m.replayCached(w, r, dataKey); handled {
+		handled, err := m.replayCached(w, r, dataKey)
+		if err != nil {
+			m.requireDegraded(w, r, next, key)
+			return
+		}
+		if handled {
 			fmt.Printf("[DEBUG-FIX] Idempotency Hit (Cache) - Key: %s\n", key)
 			return
 		}
@@ -62,7 +115,7 @@ func (m *IdempotencyMiddleware) Require(next http.Handler) http.Handler {
 		ok, err := m.cache.SetNX(r.Context(), lockKey, requestID, m.ttl).Result()
 		if err != nil {
 			fmt.Printf("[DEBUG-FIX] Idempotency Redis Error - Key: %s, Error: %v\n", key, err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			m.requireDegraded(w, r, next, key)
 			return
 		}
 
@@ -81,7 +134,7 @@ func (m *IdempotencyMiddleware) Require(next http.Handler) http.Handler {
 			// This prevents "Duplicate request" errors on double-clicks and slow processing
 			for i := 0; i < 50; i++ {
 				time.Sleep(100 * time.Millisecond)
-				if handled := m.replayCached(w, r, dataKey); handled {
+				if handled, _ := m.replayCached(w, r, dataKey); handled {
 					fmt.Printf("[DEBUG-FIX] Idempotency Resolved after Wait - Key: %s\n", key)
 					return
 				}
@@ -95,6 +148,16 @@ func (m *IdempotencyMiddleware) Require(next http.Handler) http.Handler {
 		fmt.Printf("[DEBUG-FIX] Idempotency Lock Acquired - Key: %s\n", key)
 		defer m.cache.Del(r.Context(), lockKey)
 
+		// Keys taken in the store while Redis was down still stand
+		if m.hasFallback(r) {
+			if taken, payload, err := m.store.Lookup(r.Context(), r.Method+":"+key); err == nil && taken {
+				if payload == nil || !replay(w, payload) {
+					http.Error(w, kyderrors.ErrDuplicateRequest.Error(), http.StatusConflict)
+				}
+				return
+			}
+		}
+
 		// Capture response
 		cw := newCaptureWriter(w, 1<<20) // 1MB cap
 		next.ServeHTTP(cw, r)
@@ -110,12 +173,65 @@ type capturedResponse struct {
 	Headers map[string]string `json:"headers"`
 }
 
-func (m *IdempotencyMiddleware) replayCached(w http.ResponseWriter, r *http.Request, dataKey string) bool {
+// requireDegraded handles a request while Redis is down, as the failure
+// mode says.
+func (m *IdempotencyMiddleware) requireDegraded(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	switch {
+	case m.failure == FailOpen:
+		metrics.ObserveDegraded("idempotency", "allowed")
+		next.ServeHTTP(w, r)
+	case m.failure == FailLocal && m.hasFallback(r):
+		m.requireStored(w, r, next, r.Method+":"+key)
+	default:
+		metrics.ObserveDegraded("idempotency", "rejected")
+		http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// requireStored runs the request holding key in the store, or replays the
+// response of the request that already did.
+func (m *IdempotencyMiddleware) requireStored(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	claimed, err := m.store.Claim(r.Context(), key, m.ttl)
+	if err != nil {
+		metrics.ObserveDegraded("idempotency", "rejected")
+		http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	metrics.ObserveDegraded("idempotency", "local")
+	if !claimed {
+		_, payload, err := m.store.Lookup(r.Context(), key)
+		if err != nil || payload == nil || !replay(w, payload) {
+			http.Error(w, kyderrors.ErrDuplicateRequest.Error(), http.StatusConflict)
+		}
+		return
+	}
+
+	cw := newCaptureWriter(w, 1<<20)
+	next.ServeHTTP(cw, r)
+
+	// The key outlives the request only with a response to replay
+	if payload, err := encodeResponse(cw); err == nil && payload != nil {
+		if err := m.store.Complete(context.WithoutCancel(r.Context()), key, payload); err == nil {
+			return
+		}
+	}
+	_ = m.store.Release(context.WithoutCancel(r.Context()), key)
+}
+
+// replayCached writes the response cached under dataKey, reporting false
+// when there is none and an error when Redis could not be asked.
+func (m *IdempotencyMiddleware) replayCached(w http.ResponseWriter, r *http.Request, dataKey string) (bool, error) {
 	payload, err := m.cache.Get(r.Context(), dataKey).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
 	if err != nil {
-		return false
+		return false, err
 	}
+	return replay(w, payload), nil
+}
 
+func replay(w http.ResponseWriter, payload []byte) bool {
 	var cr capturedResponse
 	if err := json.Unmarshal(payload, &cr); err != nil {
 		return false
@@ -130,9 +246,21 @@ func (m *IdempotencyMiddleware) replayCached(w http.ResponseWriter, r *http.Requ
 }
 
 func (m *IdempotencyMiddleware) cacheResponse(r *http.Request, dataKey string, cw *captureWriter) error {
+	payload, err := encodeResponse(cw)
+	if err != nil || payload == nil {
+		return err
+	}
+
+	// Set with TTL
+	return m.cache.Set(r.Context(), dataKey, payload, m.ttl).Err()
+}
+
+// encodeResponse returns the captured response to replay, or nil when
+// there is nothing worth replaying.
+func encodeResponse(cw *captureWriter) ([]byte, error) {
 	// Do not cache empty or oversized responses
 	if cw.status == 0 || len(cw.buf) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	resp := capturedResponse{
@@ -140,14 +268,7 @@ func (m *IdempotencyMiddleware) cacheResponse(r *http.Request, dataKey string, c
 		Body:    cw.buf,
 		Headers: cw.headers,
 	}
-
-	payload, err := json.Marshal(resp)
-	if err != nil {
-		return err
-	}
-
-	// Set with TTL
-	return m.cache.Set(r.Context(), dataKey, payload, m.ttl).Err()
+	return json.Marshal(resp)
 }
 
 type captureWriter struct {
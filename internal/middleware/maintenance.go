@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaintenanceChecker reports whether a service is in maintenance.
+type MaintenanceChecker interface {
+	InMaintenance(ctx context.Context, service string) (message string, retryAfter time.Duration, active bool)
+}
+
+// RejectDuringMaintenance answers 503 with a Retry-After header while service
+// is in maintenance. Wrap money-movement endpoints only, so balances, history
+// and rates remain readable.
+func RejectDuringMaintenance(checker MaintenanceChecker, service string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			message, retryAfter, active := checker.InMaintenance(r.Context(), service)
+			if !active {
+				next.ServeHTTP(w, r)
+				return
+			}
+			seconds := int(retryAfter.Seconds())
+			if seconds > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":       message,
+				"code":        "maintenance",
+				"service":     service,
+				"retry_after": seconds,
+			})
+		})
+	}
+}
@@ -2,11 +2,15 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"time"
 
+	"kyd/pkg/metrics"
+
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
@@ -17,6 +21,8 @@ import (
 // ARGV[2]: Window duration (ms)
 // ARGV[3]: Max requests limit
 // ARGV[4]: Unique request ID (member)
+// Returns {count, oldest}: the requests in the window including this one, or
+// -1 when it was rejected, and the timestamp (ms) of the oldest of them.
 var slidingWindowScript = redis.NewScript(`
 	local key = KEYS[1]
 	local now = tonumber(ARGV[1])
@@ -30,24 +36,33 @@ var slidingWindowScript = redis.NewScript(`
 	-- Count requests in the current window
 	local count = redis.call('ZCARD', key)
 
+	local oldest = now
+	local first = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	if first[2] then
+		oldest = tonumber(first[2])
+	end
+
 	if count >= limit then
-		return -1
+		return {-1, oldest}
 	end
 
 	-- Add current request
 	redis.call('ZADD', key, now, member)
 	redis.call('PEXPIRE', key, window)
 
-	return count + 1
+	return {count + 1, oldest}
 `)
 
 // RateLimiter applies a sliding-window rate limit backed by Redis with adaptive blocking.
 type RateLimiter struct {
 	cache        *redis.Client
+	name         string
 	limit        int
 	window       time.Duration
 	banThreshold int
 	banDuration  time.Duration
+	failure      FailureMode
+	local        *localWindow
 }
 
 // NewRateLimiter constructs a RateLimiter with the given limit and window.
@@ -58,7 +73,20 @@ func NewRateLimiter(cache *redis.Client, limit int, window time.Duration) *RateL
 		window:       window,
 		banThreshold: 5,                // Default: 5 violations triggers ban
 		banDuration:  15 * time.Minute, // Default: 15 minute ban
+		failure:      FailOpen,
+	}
+}
+
+// WithFailureMode sets what the limiter does while Redis is down: let
+// requests through (the default), refuse them, or count them in memory.
+func (rl *RateLimiter) WithFailureMode(mode FailureMode) *RateLimiter {
+	rl.failure = mode
+	if mode == FailLocal {
+		rl.local = newLocalWindow(rl.limit, rl.window)
+	} else {
+		rl.local = nil
 	}
+	return rl
 }
 
 // WithAdaptive configures the adaptive blocking parameters.
@@ -68,29 +96,69 @@ func (rl *RateLimiter) WithAdaptive(threshold int, duration time.Duration) *Rate
 	return rl
 }
 
+// WithName gives the limiter its own counters. Limiters without a name share
+// one window per client, so stacked limiters count every request twice and
+// the strictest of them throttles routes it was never meant for.
+func (rl *RateLimiter) WithName(name string) *RateLimiter {
+	rl.name = name
+	return rl
+}
+
+// Name identifies the limiter in quota reports.
+func (rl *RateLimiter) Name() string {
+	return rl.name
+}
+
+// ClientIP returns the request's remote address without its port.
+func ClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// key returns the counter key of a client: its IP and, when authenticated,
+// user ID.
+func (rl *RateLimiter) key(ip string, userID uuid.UUID) string {
+	prefix := "ratelimit"
+	if rl.name != "" {
+		prefix = "ratelimit:" + rl.name
+	}
+	if userID != uuid.Nil {
+		return fmt.Sprintf("%s:%s:%s", prefix, ip, userID.String())
+	}
+	return fmt.Sprintf("%s:%s", prefix, ip)
+}
+
+func isAdminRequest(r *http.Request) bool {
+	ut, ok := r.Context().Value(ctxUserTypeKey).(string)
+	return ok && ut == "admin"
+}
+
 // Limit enforces the rate limit, keyed by client IP and, when available, user ID.
+// Every response carries X-RateLimit-Limit, -Remaining and -Reset (Unix
+// seconds at which a slot frees up); when limiters are stacked the one with
+// the fewest requests remaining sets them.
 func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if ut, ok := r.Context().Value(ctxUserTypeKey).(string); ok && ut == "admin" {
+		if isAdminRequest(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		ip := r.RemoteAddr
-		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-			ip = host
-		}
-
 		userID, _ := r.Context().Value(ctxUserIDKey).(uuid.UUID)
-		baseKey := fmt.Sprintf("ratelimit:%s", ip)
-		if userID != uuid.Nil {
-			baseKey = fmt.Sprintf("ratelimit:%s:%s", ip, userID.String())
-		}
+		baseKey := rl.key(ClientIP(r), userID)
 
 		// 1. Check if user is banned
 		banKey := fmt.Sprintf("%s:ban", baseKey)
-		if rl.cache.Exists(r.Context(), banKey).Val() > 0 {
-			w.Header().Set("Retry-After", fmt.Sprintf("%.0f", rl.banDuration.Seconds()))
+		ttl, err := rl.cache.PTTL(r.Context(), banKey).Result()
+		if err != nil {
+			rl.limitDegraded(w, r, next, baseKey)
+			return
+		}
+		if ttl > 0 {
+			setRateLimitHeaders(w, rl.limit, 0, time.Now().Add(ttl))
+			w.Header().Set("Retry-After", fmt.Sprintf("%.0f", ttl.Seconds()))
 			http.Error(w, "Too many requests. Temporary ban active.", http.StatusTooManyRequests)
 			return
 		}
@@ -100,12 +168,12 @@ func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 		windowMs := rl.window.Milliseconds()
 		reqID := uuid.New().String()
 
-		result, err := slidingWindowScript.Run(r.Context(), rl.cache, []string{baseKey}, now, windowMs, rl.limit, reqID).Int()
-		if err != nil && err != redis.Nil {
-			// Fail open to avoid blocking legitimate users on redis error
-			next.ServeHTTP(w, r)
+		res, err := slidingWindowScript.Run(r.Context(), rl.cache, []string{baseKey}, now, windowMs, rl.limit, reqID).Int64Slice()
+		if err != nil || len(res) != 2 {
+			rl.limitDegraded(w, r, next, baseKey)
 			return
 		}
+		result, reset := res[0], time.UnixMilli(res[1]+windowMs)
 
 		// 3. Check limit result
 		if result == -1 {
@@ -120,17 +188,129 @@ func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 			if vCount >= int64(rl.banThreshold) {
 				rl.cache.Set(r.Context(), banKey, "banned", rl.banDuration)
 				rl.cache.Del(r.Context(), violationKey) // Reset violations after ban
+				reset = time.Now().Add(rl.banDuration)
 			}
 
-			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rl.limit))
-			w.Header().Set("X-RateLimit-Remaining", "0")
+			setRateLimitHeaders(w, rl.limit, 0, reset)
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(reset).Seconds()+1), 10))
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
 
-		w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rl.limit))
-		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", rl.limit-result))
+		setRateLimitHeaders(w, rl.limit, rl.limit-int(result), reset)
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// limitDegraded handles a request Redis could not count, as the failure
+// mode says.
+func (rl *RateLimiter) limitDegraded(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	label := "ratelimit"
+	if rl.name != "" {
+		label += ":" + rl.name
+	}
+	switch rl.failure {
+	case FailClosed:
+		metrics.ObserveDegraded(label, "rejected")
+		http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+	case FailLocal:
+		remaining, reset, ok := rl.local.allow(key, time.Now())
+		setRateLimitHeaders(w, rl.limit, remaining, reset)
+		if !ok {
+			metrics.ObserveDegraded(label, "rejected")
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Until(reset).Seconds()+1), 10))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		metrics.ObserveDegraded(label, "local")
+		next.ServeHTTP(w, r)
+	default:
+		metrics.ObserveDegraded(label, "allowed")
+		next.ServeHTTP(w, r)
+	}
+}
+
+// setRateLimitHeaders writes the quota headers unless an outer limiter has
+// already reported fewer requests remaining.
+func setRateLimitHeaders(w http.ResponseWriter, limit, remaining int, reset time.Time) {
+	if prev := w.Header().Get("X-RateLimit-Remaining"); prev != "" {
+		if n, err := strconv.Atoi(prev); err == nil && n < remaining {
+			return
+		}
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+}
+
+// Quota is a client's standing against one limiter.
+type Quota struct {
+	Name          string    `json:"name"`
+	Limit         int       `json:"limit"`
+	Remaining     int       `json:"remaining"`
+	WindowSeconds int64     `json:"window_seconds"`
+	ResetAt       time.Time `json:"reset_at"`
+	Banned        bool      `json:"banned"`
+	// Exempt is set for admins, whom the limiter does not apply to.
+	Exempt bool `json:"exempt,omitempty"`
+}
+
+// Quota reports a client's standing without counting a request. Limiters
+// mounted ahead of authentication count per IP, so their quota must be read
+// with uuid.Nil.
+func (rl *RateLimiter) Quota(ctx context.Context, ip string, userID uuid.UUID) (Quota, error) {
+	q := Quota{
+		Name:          rl.name,
+		Limit:         rl.limit,
+		Remaining:     rl.limit,
+		WindowSeconds: int64(rl.window.Seconds()),
+		ResetAt:       time.Now(),
+	}
+	if ut, _ := ctx.Value(ctxUserTypeKey).(string); ut == "admin" {
+		q.Exempt = true
+		return q, nil
+	}
+
+	baseKey := rl.key(ip, userID)
+	ttl, err := rl.cache.PTTL(ctx, baseKey+":ban").Result()
+	if err != nil {
+		return rl.localQuota(baseKey, q, err)
+	}
+	if ttl > 0 {
+		q.Banned, q.Remaining, q.ResetAt = true, 0, time.Now().Add(ttl)
+		return q, nil
+	}
+	q, err = rl.windowQuota(ctx, baseKey, q)
+	if err != nil {
+		return rl.localQuota(baseKey, q, err)
+	}
+	return q, nil
+}
+
+// localQuota reports the in-memory window of a limiter counting there
+// while Redis is down, and otherwise the Redis error.
+func (rl *RateLimiter) localQuota(key string, q Quota, err error) (Quota, error) {
+	if rl.local == nil {
+		return q, err
+	}
+	q.Remaining, q.ResetAt = rl.local.quota(key, time.Now())
+	return q, nil
+}
+
+func (rl *RateLimiter) windowQuota(ctx context.Context, key string, q Quota) (Quota, error) {
+	now := time.Now()
+	from := strconv.FormatInt(now.Add(-rl.window).UnixMilli(), 10)
+	inWindow, err := rl.cache.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Min: "(" + from, Max: "+inf"}).Result()
+	if err != nil {
+		return q, err
+	}
+	q.Remaining = rl.limit - len(inWindow)
+	if q.Remaining < 0 {
+		q.Remaining = 0
+	}
+	if len(inWindow) > 0 {
+		q.ResetAt = time.UnixMilli(int64(inWindow[0].Score)).Add(rl.window)
+	}
+	return q, nil
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// localWindow is the sliding window a RateLimiter counts in while Redis is
+// down. It lives in one process, so each instance allows the full limit,
+// and it does not ban.
+type localWindow struct {
+	limit  int
+	window time.Duration
+
+	mu    sync.Mutex
+	hits  map[string][]time.Time
+	swept time.Time
+}
+
+func newLocalWindow(limit int, window time.Duration) *localWindow {
+	return &localWindow{limit: limit, window: window, hits: make(map[string][]time.Time)}
+}
+
+// allow counts a request by key at now unless the window is full. It
+// returns the requests left and when the oldest in the window leaves it.
+func (l *localWindow) allow(key string, now time.Time) (remaining int, reset time.Time, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	hits := l.inWindow(key, now)
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return 0, hits[0].Add(l.window), false
+	}
+	hits = append(hits, now)
+	l.hits[key] = hits
+	return l.limit - len(hits), hits[0].Add(l.window), true
+}
+
+// quota returns what allow would, without counting a request.
+func (l *localWindow) quota(key string, now time.Time) (remaining int, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hits := l.inWindow(key, now)
+	if len(hits) == 0 {
+		return l.limit, now
+	}
+	remaining = l.limit - len(hits)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, hits[0].Add(l.window)
+}
+
+func (l *localWindow) inWindow(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	from := now.Add(-l.window)
+	i := 0
+	for i < len(hits) && !hits[i].After(from) {
+		i++
+	}
+	return hits[i:]
+}
+
+// sweep drops the clients with nothing left in the window, once per window.
+func (l *localWindow) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.window {
+		return
+	}
+	l.swept = now
+	for key := range l.hits {
+		if len(l.inWindow(key, now)) == 0 {
+			delete(l.hits, key)
+		}
+	}
+}
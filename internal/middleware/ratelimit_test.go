@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRateLimitHeadersKeepsMostRestrictive(t *testing.T) {
+	rec := httptest.NewRecorder()
+	reset := time.Unix(1700000000, 0)
+
+	setRateLimitHeaders(rec, 150, 140, reset)
+	setRateLimitHeaders(rec, 60, 3, reset.Add(time.Minute))
+	// An inner limiter with more headroom does not hide the tighter one.
+	setRateLimitHeaders(rec, 80, 50, reset)
+
+	assert.Equal(t, "60", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "3", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1700000060", rec.Header().Get("X-RateLimit-Reset"))
+}
+
+func TestRateLimiterKeysAreScopedByName(t *testing.T) {
+	user := uuid.New()
+	unnamed := NewRateLimiter(nil, 10, time.Minute)
+	auth := NewRateLimiter(nil, 5, time.Minute).WithName("auth")
+
+	assert.Equal(t, "ratelimit:10.0.0.1", unnamed.key("10.0.0.1", uuid.Nil))
+	assert.Equal(t, "ratelimit:auth:10.0.0.1", auth.key("10.0.0.1", uuid.Nil))
+	assert.Equal(t, "ratelimit:auth:10.0.0.1:"+user.String(), auth.key("10.0.0.1", user))
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const ctxPermissionsKey contextKey = "permissions"
+
+// PermissionSource looks up what a staff member's roles allow.
+type PermissionSource interface {
+	Permissions(ctx context.Context, userID uuid.UUID) (domain.PermissionSet, error)
+}
+
+// Authorizer enforces staff permissions. Load puts the caller's permissions
+// on the context; Require and Guard check them.
+type Authorizer struct {
+	source PermissionSource
+	logger logger.Logger
+}
+
+func NewAuthorizer(source PermissionSource, log logger.Logger) *Authorizer {
+	return &Authorizer{source: source, logger: log}
+}
+
+// Load looks up the permissions of admin callers. It must run after
+// Authenticate; other callers pass through with no permissions.
+func (a *Authorizer) Load(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ut, _ := UserTypeFromContext(r.Context())
+		userID, ok := UserIDFromContext(r.Context())
+		if ut != string(domain.UserTypeAdmin) || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		perms, err := a.source.Permissions(r.Context(), userID)
+		if err != nil {
+			a.logger.Error("Failed to load staff permissions", map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			respondJSONError(w, http.StatusServiceUnavailable, "Unable to check permissions")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPermissions(r.Context(), perms)))
+	})
+}
+
+// Require lets through staff holding perm.
+func (a *Authorizer) Require(perm domain.Permission) func(http.Handler) http.Handler {
+	return a.Guard(func(string, string) (domain.Permission, bool) { return perm, true })
+}
+
+// Guard lets through staff holding the permission resolve returns for the
+// request. An empty permission admits any staff member; a request resolve
+// does not cover needs every permission.
+func (a *Authorizer) Guard(resolve func(method, path string) (domain.Permission, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !IsStaff(r.Context()) {
+				respondJSONError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			perm, ok := resolve(r.Method, r.URL.Path)
+			if !ok {
+				perm = domain.PermissionAll
+			}
+			if perm != "" && !HasPermission(r.Context(), perm) {
+				respondJSONError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithPermissions returns ctx carrying a staff member's permissions.
+func WithPermissions(ctx context.Context, perms domain.PermissionSet) context.Context {
+	return context.WithValue(ctx, ctxPermissionsKey, perms)
+}
+
+// PermissionsFromContext returns the caller's permissions, or nil for
+// callers who are not staff.
+func PermissionsFromContext(ctx context.Context) domain.PermissionSet {
+	perms, _ := ctx.Value(ctxPermissionsKey).(domain.PermissionSet)
+	return perms
+}
+
+// IsStaff reports whether the caller is an admin account whose permissions
+// were loaded, whatever they are.
+func IsStaff(ctx context.Context) bool {
+	return PermissionsFromContext(ctx) != nil
+}
+
+// HasPermission reports whether the caller's roles allow perm.
+func HasPermission(ctx context.Context, perm domain.Permission) bool {
+	return PermissionsFromContext(ctx).Has(perm)
+}
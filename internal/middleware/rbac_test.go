@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedPermissions map[uuid.UUID]domain.PermissionSet
+
+func (f fixedPermissions) Permissions(ctx context.Context, userID uuid.UUID) (domain.PermissionSet, error) {
+	if set, ok := f[userID]; ok {
+		return set, nil
+	}
+	return domain.NewPermissionSet(), nil
+}
+
+func TestAuthorizerGuard(t *testing.T) {
+	support, admin, customer := uuid.New(), uuid.New(), uuid.New()
+	authz := NewAuthorizer(fixedPermissions{
+		support: domain.NewPermissionSet(domain.PermissionUsersRead),
+		admin:   domain.NewPermissionSet(domain.PermissionAll),
+	}, logger.NewNop())
+	resolve := func(method, path string) (domain.Permission, bool) {
+		switch path {
+		case "/users":
+			return domain.PermissionUsersRead, true
+		case "/ledger":
+			return domain.PermissionTreasuryRead, true
+		case "/permissions":
+			return "", true
+		}
+		return "", false
+	}
+	h := authz.Load(authz.Guard(resolve)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	call := func(userID uuid.UUID, userType, path string) int {
+		ctx := context.WithValue(context.Background(), ctxUserIDKey, userID)
+		ctx = context.WithValue(ctx, ctxUserTypeKey, userType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, call(support, "admin", "/users"))
+	assert.Equal(t, http.StatusForbidden, call(support, "admin", "/ledger"))
+	assert.Equal(t, http.StatusOK, call(support, "admin", "/permissions"))
+	assert.Equal(t, http.StatusForbidden, call(support, "admin", "/unmapped"), "unmapped paths need every permission")
+	assert.Equal(t, http.StatusOK, call(admin, "admin", "/unmapped"))
+
+	// Roles only count on admin accounts
+	assert.Equal(t, http.StatusForbidden, call(support, "individual", "/users"))
+	assert.Equal(t, http.StatusForbidden, call(customer, "individual", "/permissions"))
+	assert.Equal(t, http.StatusOK, call(customer, "admin", "/permissions"), "staff without roles may still see their own permissions")
+	assert.Equal(t, http.StatusForbidden, call(customer, "admin", "/users"))
+}
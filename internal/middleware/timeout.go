@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// QueryDeadlines puts a deadline on each request's context so the database
+// statements it issues are cancelled server-side once it passes. Routes get
+// the default unless a longer or shorter budget is registered for their path
+// prefix, which keeps a slow admin report from holding pool connections that
+// payments need.
+type QueryDeadlines struct {
+	def      time.Duration
+	prefixes []string
+	budgets  map[string]time.Duration
+}
+
+func NewQueryDeadlines(def time.Duration) *QueryDeadlines {
+	return &QueryDeadlines{def: def, budgets: make(map[string]time.Duration)}
+}
+
+// WithPrefix sets the budget for paths under prefix. The longest matching
+// prefix wins; a zero budget leaves those requests without a deadline.
+func (q *QueryDeadlines) WithPrefix(prefix string, budget time.Duration) *QueryDeadlines {
+	if _, ok := q.budgets[prefix]; !ok {
+		q.prefixes = append(q.prefixes, prefix)
+	}
+	q.budgets[prefix] = budget
+	return q
+}
+
+// Budget returns the deadline applied to requests for path.
+func (q *QueryDeadlines) Budget(path string) time.Duration {
+	budget, matched := q.def, ""
+	for _, p := range q.prefixes {
+		if strings.HasPrefix(path, p) && len(p) > len(matched) {
+			budget, matched = q.budgets[p], p
+		}
+	}
+	return budget
+}
+
+func (q *QueryDeadlines) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget := q.Budget(r.URL.Path)
+		if budget <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
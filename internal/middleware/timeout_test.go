@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryDeadlines(t *testing.T) {
+	q := NewQueryDeadlines(5*time.Second).
+		WithPrefix("/api/v1/admin", 30*time.Second).
+		WithPrefix("/api/v1/admin/exports", 0)
+
+	assert.Equal(t, 5*time.Second, q.Budget("/api/v1/payments"))
+	assert.Equal(t, 30*time.Second, q.Budget("/api/v1/admin/analytics/funnel"))
+	assert.Equal(t, time.Duration(0), q.Budget("/api/v1/admin/exports/1"))
+
+	var remaining time.Duration
+	var hasDeadline bool
+	h := q.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var deadline time.Time
+		deadline, hasDeadline = r.Context().Deadline()
+		remaining = time.Until(deadline)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/admin/analytics/funnel", nil))
+	assert.True(t, hasDeadline)
+	assert.InDelta(t, float64(30*time.Second), float64(remaining), float64(time.Second))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/admin/exports/1", nil))
+	assert.False(t, hasDeadline)
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	kyderrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationChecker reports whether a user may move money given the
+// state of their email verification.
+type EmailVerificationChecker interface {
+	CheckEmailVerified(ctx context.Context, userID uuid.UUID) error
+}
+
+// RequireVerifiedEmail rejects requests from users whose email verification
+// grace period has ended. It must run after Authenticate.
+func RequireVerifiedEmail(checker EmailVerificationChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := UserIDFromContext(r.Context())
+			if !ok {
+				respondJSONError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if err := checker.CheckEmailVerified(r.Context(), userID); err != nil {
+				if errors.Is(err, kyderrors.ErrEmailNotVerified) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusForbidden)
+					json.NewEncoder(w).Encode(map[string]string{
+						"error": "Please verify your email address to continue",
+						"code":  "email_verification_required",
+					})
+					return
+				}
+				respondJSONError(w, http.StatusServiceUnavailable, "Unable to check email verification")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
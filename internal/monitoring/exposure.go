@@ -0,0 +1,185 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// ExposureSource values what is outstanding with each counterparty.
+// Satisfied by counterparty.Service.
+type ExposureSource interface {
+	Exposures(ctx context.Context) ([]*domain.CounterpartyExposure, error)
+}
+
+// AdminDirectory lists the admins ops alerts go to.
+type AdminDirectory interface {
+	ListAdminIDs(ctx context.Context) ([]uuid.UUID, error)
+}
+
+// ExposureMonitor alerts ops when a counterparty's exposure passes the soft
+// threshold and again when it reaches its limit, where routing to it pauses.
+type ExposureMonitor struct {
+	source   ExposureSource
+	admins   AdminDirectory
+	events   SecurityEventLogger
+	notifier Notifier
+	cfg      config.ExposureAlertConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewExposureMonitor(source ExposureSource, admins AdminDirectory, events SecurityEventLogger, notifier Notifier, cfg config.ExposureAlertConfig, log logger.Logger) *ExposureMonitor {
+	return &ExposureMonitor{
+		source:    source,
+		admins:    admins,
+		events:    events,
+		notifier:  notifier,
+		cfg:       cfg,
+		logger:    log,
+		now:       time.Now,
+		lastAlert: make(map[string]time.Time),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start runs Check every configured interval until Stop is called.
+func (m *ExposureMonitor) Start() {
+	if !m.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(m.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Interval)
+				if _, err := m.Check(ctx); err != nil {
+					m.logger.Error("Counterparty exposure check failed", map[string]interface{}{"error": err.Error()})
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func (m *ExposureMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// Check raises an alert for each counterparty at a warning or breached
+// level. Alerts for the same counterparty and level are suppressed for the
+// configured cooldown, so a breach still alerts straight after a warning.
+func (m *ExposureMonitor) Check(ctx context.Context) ([]*domain.CounterpartyExposure, error) {
+	exposures, err := m.source.Exposures(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var raised []*domain.CounterpartyExposure
+	for _, e := range exposures {
+		if e.Level == domain.ExposureOK || !m.claim(e) {
+			continue
+		}
+		m.raise(ctx, e)
+		raised = append(raised, e)
+	}
+	return raised, nil
+}
+
+func (m *ExposureMonitor) claim(e *domain.CounterpartyExposure) bool {
+	key := e.CounterpartyID.String() + "|" + string(e.Level)
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if last, ok := m.lastAlert[key]; ok && now.Sub(last) < m.cfg.Cooldown {
+		return false
+	}
+	m.lastAlert[key] = now
+	return true
+}
+
+func (m *ExposureMonitor) raise(ctx context.Context, e *domain.CounterpartyExposure) {
+	description := describeExposure(e)
+	severity := domain.SecuritySeverityMedium
+	if e.Level == domain.ExposureBreached {
+		severity = domain.SecuritySeverityHigh
+	}
+	resourceID := e.CounterpartyID.String()
+	event := &domain.SecurityEvent{
+		ID:          uuid.New(),
+		Type:        domain.SecurityEventTypeCounterpartyExposure,
+		Severity:    severity,
+		Description: description,
+		ResourceID:  &resourceID,
+		Status:      domain.SecurityEventStatusOpen,
+		Metadata: domain.Metadata{
+			"counterparty_id":   e.CounterpartyID,
+			"counterparty_code": e.Code,
+			"level":             e.Level,
+			"exposure":          e.Exposure,
+			"limit":             e.Limit,
+			"currency":          e.Currency,
+			"utilisation":       e.Utilisation,
+		},
+		CreatedAt: m.now(),
+	}
+	if err := m.events.LogSecurityEvent(ctx, event); err != nil {
+		m.logger.Error("Failed to record counterparty exposure alert", map[string]interface{}{"error": err.Error(), "counterparty": e.Code})
+	}
+	m.logger.Warn("Counterparty exposure alert", map[string]interface{}{
+		"counterparty": e.Code,
+		"level":        e.Level,
+		"exposure":     e.Exposure,
+		"limit":        e.Limit,
+		"currency":     e.Currency,
+	})
+
+	admins, err := m.admins.ListAdminIDs(ctx)
+	if err != nil {
+		m.logger.Error("Failed to list alert recipients", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	for _, id := range admins {
+		err := m.notifier.SendRaw(ctx, &notification.Notification{
+			ID:        uuid.New(),
+			UserID:    id,
+			Type:      "OPS_ALERT",
+			Channel:   notification.ChannelEmail,
+			Priority:  notification.PriorityUrgent,
+			Subject:   "Ops alert: counterparty exposure " + e.Code,
+			Body:      description,
+			Metadata:  event.Metadata,
+			CreatedAt: event.CreatedAt,
+		})
+		if err != nil {
+			m.logger.Error("Failed to send ops alert", map[string]interface{}{"error": err.Error(), "user_id": id})
+		}
+	}
+}
+
+func describeExposure(e *domain.CounterpartyExposure) string {
+	used := e.Utilisation.Shift(2).StringFixed(1)
+	if e.Level == domain.ExposureBreached {
+		return fmt.Sprintf("Exposure to %s is %s %s, %s%% of its %s limit; settlements are no longer routed to it",
+			e.Code, e.Exposure.StringFixed(2), e.Currency, used, e.Limit.StringFixed(2))
+	}
+	return fmt.Sprintf("Exposure to %s is %s %s, %s%% of its %s limit",
+		e.Code, e.Exposure.StringFixed(2), e.Currency, used, e.Limit.StringFixed(2))
+}
@@ -0,0 +1,67 @@
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticExposures []*domain.CounterpartyExposure
+
+func (s staticExposures) Exposures(context.Context) ([]*domain.CounterpartyExposure, error) {
+	return s, nil
+}
+
+func exposure(code string, level domain.ExposureLevel, used string) *domain.CounterpartyExposure {
+	limit, utilisation := decimal.NewFromInt(1000), decimal.RequireFromString(used)
+	amount := limit.Mul(utilisation)
+	return &domain.CounterpartyExposure{
+		CounterpartyID: uuid.New(),
+		Code:           code,
+		Currency:       domain.USD,
+		Exposure:       &amount,
+		Limit:          &limit,
+		Utilisation:    &utilisation,
+		Level:          level,
+	}
+}
+
+func TestExposureMonitor(t *testing.T) {
+	warn := exposure("A-PAY", domain.ExposureWarning, "0.85")
+	source := staticExposures{warn, exposure("B-PAY", domain.ExposureOK, "0.2")}
+	events, notifier := &recordingEvents{}, &recordingNotifier{}
+	repo := &fakeMetricRepository{admins: []uuid.UUID{uuid.New(), uuid.New()}}
+	m := NewExposureMonitor(source, repo, events, notifier,
+		config.ExposureAlertConfig{Enabled: true, Interval: time.Minute, WarnAt: 0.8, Cooldown: time.Hour}, logger.NewNop())
+
+	raised, err := m.Check(context.Background())
+	require.NoError(t, err)
+	require.Len(t, raised, 1)
+	require.Len(t, events.events, 1)
+	assert.Equal(t, domain.SecurityEventTypeCounterpartyExposure, events.events[0].Type)
+	assert.Equal(t, domain.SecuritySeverityMedium, events.events[0].Severity)
+	assert.Contains(t, events.events[0].Description, "85.0%")
+	assert.Len(t, notifier.sent, 2)
+
+	raised, err = m.Check(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, raised, "suppressed within the cooldown")
+
+	breached := exposure("A-PAY", domain.ExposureBreached, "1")
+	breached.CounterpartyID = warn.CounterpartyID
+	source[0] = breached
+	raised, err = m.Check(context.Background())
+	require.NoError(t, err)
+	require.Len(t, raised, 1, "a breach alerts straight after a warning")
+	assert.Equal(t, domain.SecuritySeverityHigh, events.events[1].Severity)
+	assert.Contains(t, events.events[1].Description, "no longer routed")
+}
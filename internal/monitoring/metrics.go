@@ -0,0 +1,322 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Metric is a business metric watched for anomalies.
+type Metric string
+
+const (
+	// MetricVolume is the number of payments started per corridor and hour.
+	MetricVolume Metric = "volume"
+	// MetricDeclineRate is the share of those payments that failed.
+	MetricDeclineRate Metric = "decline_rate"
+	// MetricSettlementLatency is the mean seconds from creation to
+	// completion of settlements finished per network and hour.
+	MetricSettlementLatency Metric = "settlement_latency"
+)
+
+// Sample is one hour of a metric for one corridor. Count is the number of
+// transactions or settlements the value was computed from.
+type Sample struct {
+	Corridor string    `db:"corridor"`
+	Hour     time.Time `db:"hour"`
+	Value    float64   `db:"value"`
+	Count    int       `db:"count"`
+}
+
+type MetricRepository interface {
+	// HourlySamples returns the metric per corridor for each hour in
+	// [from, to) that had any activity.
+	HourlySamples(ctx context.Context, metric Metric, from, to time.Time) ([]Sample, error)
+	ListAdminIDs(ctx context.Context) ([]uuid.UUID, error)
+}
+
+type SecurityEventLogger interface {
+	LogSecurityEvent(ctx context.Context, event *domain.SecurityEvent) error
+}
+
+type Notifier interface {
+	SendRaw(ctx context.Context, n *notification.Notification) error
+}
+
+// MetricAlert describes one anomalous hour.
+type MetricAlert struct {
+	Metric   Metric
+	Corridor string
+	Hour     time.Time
+	Current  float64
+	Mean     float64
+	StdDev   float64
+	Score    float64
+}
+
+// MetricDetector compares the last complete hour of each business metric
+// with the same hour on previous days and raises ops alerts on deviations.
+type MetricDetector struct {
+	repo     MetricRepository
+	events   SecurityEventLogger
+	notifier Notifier
+	cfg      config.MetricAlertConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewMetricDetector(repo MetricRepository, events SecurityEventLogger, notifier Notifier, cfg config.MetricAlertConfig, log logger.Logger) *MetricDetector {
+	return &MetricDetector{
+		repo:      repo,
+		events:    events,
+		notifier:  notifier,
+		cfg:       cfg,
+		logger:    log,
+		now:       time.Now,
+		lastAlert: make(map[string]time.Time),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start runs Check every configured interval until Stop is called.
+func (d *MetricDetector) Start() {
+	if !d.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(d.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Interval)
+				if _, err := d.Check(ctx); err != nil {
+					d.logger.Error("Metric anomaly check failed", map[string]interface{}{"error": err.Error()})
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func (d *MetricDetector) Stop() {
+	d.stopOnce.Do(func() { close(d.stop) })
+}
+
+// Check evaluates the last complete hour and raises an alert for each new
+// anomaly. Alerts for the same metric and corridor are suppressed for the
+// configured cooldown.
+func (d *MetricDetector) Check(ctx context.Context) ([]MetricAlert, error) {
+	hour := d.now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	from := hour.AddDate(0, 0, -d.cfg.BaselineDays)
+	to := hour.Add(time.Hour)
+
+	var raised []MetricAlert
+	for _, metric := range []Metric{MetricVolume, MetricDeclineRate, MetricSettlementLatency} {
+		samples, err := d.repo.HourlySamples(ctx, metric, from, to)
+		if err != nil {
+			return raised, err
+		}
+		for _, a := range d.evaluate(metric, hour, samples) {
+			if !d.claim(a) {
+				continue
+			}
+			d.raise(ctx, a)
+			raised = append(raised, a)
+		}
+	}
+	return raised, nil
+}
+
+// evaluate finds the corridors whose value at hour deviates from their
+// baseline, the same hour on each of the previous BaselineDays days.
+func (d *MetricDetector) evaluate(metric Metric, hour time.Time, samples []Sample) []MetricAlert {
+	type series struct {
+		current  *Sample
+		baseline map[time.Time]Sample
+	}
+	byCorridor := make(map[string]*series)
+	for i := range samples {
+		s := samples[i]
+		h := s.Hour.UTC().Truncate(time.Hour)
+		c, ok := byCorridor[s.Corridor]
+		if !ok {
+			c = &series{baseline: make(map[time.Time]Sample)}
+			byCorridor[s.Corridor] = c
+		}
+		switch {
+		case h.Equal(hour):
+			c.current = &s
+		case h.Hour() == hour.Hour():
+			c.baseline[h] = s
+		}
+	}
+
+	var alerts []MetricAlert
+	for corridor, c := range byCorridor {
+		var values []float64
+		for day := 1; day <= d.cfg.BaselineDays; day++ {
+			s, ok := c.baseline[hour.AddDate(0, 0, -day)]
+			switch {
+			case ok && (metric == MetricVolume || s.Count >= d.cfg.MinSamples):
+				values = append(values, s.Value)
+			case !ok && metric == MetricVolume:
+				// A quiet hour is a real zero for volume; rates and
+				// latencies have no value without traffic.
+				values = append(values, 0)
+			}
+		}
+		if len(values) < 2 {
+			continue
+		}
+		mean, std := meanStdDev(values)
+
+		current := 0.0
+		if c.current != nil {
+			current = c.current.Value
+		}
+		if metric != MetricVolume && (c.current == nil || c.current.Count < d.cfg.MinSamples) {
+			continue
+		}
+		if metric == MetricVolume && mean < d.cfg.MinVolume {
+			continue
+		}
+
+		// Floor the deviation so a perfectly flat baseline does not turn
+		// every small wobble into an alert.
+		score := (current - mean) / math.Max(std, math.Max(mean*0.1, 1e-9))
+		anomalous := score >= d.cfg.Threshold
+		if metric == MetricVolume {
+			anomalous = -score >= d.cfg.Threshold
+		}
+		if !anomalous {
+			continue
+		}
+		alerts = append(alerts, MetricAlert{
+			Metric:   metric,
+			Corridor: corridor,
+			Hour:     hour,
+			Current:  current,
+			Mean:     mean,
+			StdDev:   std,
+			Score:    score,
+		})
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Corridor < alerts[j].Corridor })
+	return alerts
+}
+
+func (d *MetricDetector) claim(a MetricAlert) bool {
+	key := string(a.Metric) + "|" + a.Corridor
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.lastAlert[key]; ok && now.Sub(last) < d.cfg.Cooldown {
+		return false
+	}
+	d.lastAlert[key] = now
+	return true
+}
+
+func (d *MetricDetector) raise(ctx context.Context, a MetricAlert) {
+	description := describe(a)
+	severity := domain.SecuritySeverityMedium
+	if math.Abs(a.Score) >= 2*d.cfg.Threshold || (a.Metric == MetricVolume && a.Current == 0) {
+		severity = domain.SecuritySeverityHigh
+	}
+	corridor := a.Corridor
+	event := &domain.SecurityEvent{
+		ID:          uuid.New(),
+		Type:        domain.SecurityEventTypeMetricAnomaly,
+		Severity:    severity,
+		Description: description,
+		ResourceID:  &corridor,
+		Status:      domain.SecurityEventStatusOpen,
+		Metadata: domain.Metadata{
+			"metric":          a.Metric,
+			"corridor":        a.Corridor,
+			"hour":            a.Hour,
+			"current":         a.Current,
+			"baseline_mean":   a.Mean,
+			"baseline_stddev": a.StdDev,
+			"score":           a.Score,
+		},
+		CreatedAt: d.now(),
+	}
+	if err := d.events.LogSecurityEvent(ctx, event); err != nil {
+		d.logger.Error("Failed to record metric anomaly", map[string]interface{}{"error": err.Error(), "metric": a.Metric, "corridor": a.Corridor})
+	}
+	d.logger.Warn("Metric anomaly detected", map[string]interface{}{
+		"metric":   a.Metric,
+		"corridor": a.Corridor,
+		"current":  a.Current,
+		"mean":     a.Mean,
+		"score":    a.Score,
+	})
+
+	admins, err := d.repo.ListAdminIDs(ctx)
+	if err != nil {
+		d.logger.Error("Failed to list alert recipients", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	for _, id := range admins {
+		err := d.notifier.SendRaw(ctx, &notification.Notification{
+			ID:        uuid.New(),
+			UserID:    id,
+			Type:      "OPS_ALERT",
+			Channel:   notification.ChannelEmail,
+			Priority:  notification.PriorityUrgent,
+			Subject:   "Ops alert: " + string(a.Metric) + " " + a.Corridor,
+			Body:      description,
+			Metadata:  event.Metadata,
+			CreatedAt: event.CreatedAt,
+		})
+		if err != nil {
+			d.logger.Error("Failed to send ops alert", map[string]interface{}{"error": err.Error(), "user_id": id})
+		}
+	}
+}
+
+func describe(a MetricAlert) string {
+	hour := a.Hour.Format("2006-01-02 15:04 UTC")
+	switch a.Metric {
+	case MetricVolume:
+		return fmt.Sprintf("Payment volume on %s dropped to %.0f in the hour from %s (usually %.1f)", a.Corridor, a.Current, hour, a.Mean)
+	case MetricDeclineRate:
+		return fmt.Sprintf("Decline rate on %s rose to %.1f%% in the hour from %s (usually %.1f%%)", a.Corridor, a.Current*100, hour, a.Mean*100)
+	case MetricSettlementLatency:
+		return fmt.Sprintf("Settlement latency on %s rose to %.0fs in the hour from %s (usually %.0fs)", a.Corridor, a.Current, hour, a.Mean)
+	}
+	return fmt.Sprintf("%s on %s deviated in the hour from %s", a.Metric, a.Corridor, hour)
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
@@ -0,0 +1,137 @@
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMetricRepository struct {
+	samples map[Metric][]Sample
+	admins  []uuid.UUID
+}
+
+func (f *fakeMetricRepository) HourlySamples(_ context.Context, m Metric, _, _ time.Time) ([]Sample, error) {
+	return f.samples[m], nil
+}
+
+func (f *fakeMetricRepository) ListAdminIDs(context.Context) ([]uuid.UUID, error) {
+	return f.admins, nil
+}
+
+type recordingEvents struct{ events []*domain.SecurityEvent }
+
+func (r *recordingEvents) LogSecurityEvent(_ context.Context, e *domain.SecurityEvent) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+type recordingNotifier struct{ sent []*notification.Notification }
+
+func (r *recordingNotifier) SendRaw(_ context.Context, n *notification.Notification) error {
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+var testAlertConfig = config.MetricAlertConfig{
+	Enabled:      true,
+	Interval:     time.Minute,
+	BaselineDays: 7,
+	Threshold:    3,
+	MinVolume:    5,
+	MinSamples:   20,
+	Cooldown:     time.Hour,
+}
+
+// history returns a sample at the same hour on each of the previous seven
+// days, followed by the current hour.
+func history(corridor string, hour time.Time, base []float64, count int, current *float64) []Sample {
+	var out []Sample
+	for i, v := range base {
+		out = append(out, Sample{Corridor: corridor, Hour: hour.AddDate(0, 0, -(i + 1)), Value: v, Count: count})
+	}
+	if current != nil {
+		out = append(out, Sample{Corridor: corridor, Hour: hour, Value: *current, Count: count})
+	}
+	return out
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func newTestDetector(repo *fakeMetricRepository) (*MetricDetector, *recordingEvents, *recordingNotifier) {
+	events := &recordingEvents{}
+	notifier := &recordingNotifier{}
+	d := NewMetricDetector(repo, events, notifier, testAlertConfig, logger.NewNop())
+	d.now = func() time.Time { return time.Date(2024, 5, 10, 14, 20, 0, 0, time.UTC) }
+	return d, events, notifier
+}
+
+func TestMetricDetector_VolumeDrop(t *testing.T) {
+	hour := time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)
+	base := []float64{100, 96, 104, 98, 102, 100, 99}
+	repo := &fakeMetricRepository{
+		samples: map[Metric][]Sample{
+			// MWK-CNY went silent; CNY-MWK stayed normal.
+			MetricVolume: append(history("MWK-CNY", hour, base, 100, nil),
+				history("CNY-MWK", hour, base, 100, ptr(101))...),
+		},
+		admins: []uuid.UUID{uuid.New(), uuid.New()},
+	}
+	d, events, notifier := newTestDetector(repo)
+
+	alerts, err := d.Check(context.Background())
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, MetricVolume, alerts[0].Metric)
+	assert.Equal(t, "MWK-CNY", alerts[0].Corridor)
+	assert.Equal(t, hour, alerts[0].Hour)
+
+	require.Len(t, events.events, 1)
+	assert.Equal(t, domain.SecurityEventTypeMetricAnomaly, events.events[0].Type)
+	assert.Equal(t, domain.SecuritySeverityHigh, events.events[0].Severity)
+	assert.Len(t, notifier.sent, 2)
+
+	// The same anomaly is not raised again within the cooldown.
+	alerts, err = d.Check(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestMetricDetector_DeclineSpike(t *testing.T) {
+	hour := time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)
+	base := []float64{0.02, 0.03, 0.02, 0.025, 0.02, 0.03, 0.02}
+	repo := &fakeMetricRepository{samples: map[Metric][]Sample{
+		MetricDeclineRate: append(history("MWK-CNY", hour, base, 50, ptr(0.4)),
+			// Too few transactions this hour to judge.
+			history("USD-MWK", hour, base, 5, ptr(0.8))...),
+	}}
+	d, _, _ := newTestDetector(repo)
+
+	alerts, err := d.Check(context.Background())
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, MetricDeclineRate, alerts[0].Metric)
+	assert.Equal(t, "MWK-CNY", alerts[0].Corridor)
+	assert.Greater(t, alerts[0].Score, 3.0)
+}
+
+func TestMetricDetector_QuietCorridorIgnored(t *testing.T) {
+	hour := time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)
+	repo := &fakeMetricRepository{samples: map[Metric][]Sample{
+		MetricVolume: history("ZMW-CNY", hour, []float64{2, 1, 0, 2, 1, 3, 2}, 2, nil),
+	}}
+	d, _, _ := newTestDetector(repo)
+
+	alerts, err := d.Check(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
@@ -0,0 +1,117 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// redisAlertCooldown suppresses repeat outage alerts for a service whose
+// Redis keeps dropping out.
+const redisAlertCooldown = 15 * time.Minute
+
+// RedisAlerts tells ops when a service loses Redis and runs degraded, and
+// again when it is back. It satisfies middleware.RedisAlerter.
+type RedisAlerts struct {
+	admins   AdminDirectory
+	events   SecurityEventLogger
+	notifier Notifier
+	logger   logger.Logger
+	now      func() time.Time
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time
+	alerted   map[string]bool
+}
+
+func NewRedisAlerts(admins AdminDirectory, events SecurityEventLogger, notifier Notifier, log logger.Logger) *RedisAlerts {
+	return &RedisAlerts{
+		admins:    admins,
+		events:    events,
+		notifier:  notifier,
+		logger:    log,
+		now:       time.Now,
+		lastAlert: make(map[string]time.Time),
+		alerted:   make(map[string]bool),
+	}
+}
+
+// RedisDown records a high severity event and alerts every admin, unless
+// the service's last outage was alerted within the cooldown.
+func (a *RedisAlerts) RedisDown(service string, cause error) {
+	now := a.now()
+	a.mu.Lock()
+	if last, ok := a.lastAlert[service]; ok && now.Sub(last) < redisAlertCooldown {
+		a.mu.Unlock()
+		return
+	}
+	a.lastAlert[service] = now
+	a.alerted[service] = true
+	a.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	description := fmt.Sprintf("%s lost Redis and is running degraded: rate limits, idempotency keys and token checks follow their failure modes (%s)", service, cause)
+	event := &domain.SecurityEvent{
+		ID:          uuid.New(),
+		Type:        domain.SecurityEventTypeRedisDegraded,
+		Severity:    domain.SecuritySeverityHigh,
+		Description: description,
+		ResourceID:  &service,
+		Status:      domain.SecurityEventStatusOpen,
+		Metadata:    domain.Metadata{"service": service, "error": cause.Error()},
+		CreatedAt:   now,
+	}
+	if err := a.events.LogSecurityEvent(ctx, event); err != nil {
+		a.logger.Error("Failed to record Redis outage", map[string]interface{}{"error": err.Error(), "service": service})
+	}
+	a.notify(ctx, "Ops alert: "+service+" running without Redis", description, event.Metadata)
+}
+
+// RedisRecovered alerts every admin that a service alerted on is back on
+// Redis.
+func (a *RedisAlerts) RedisRecovered(service string, downFor time.Duration) {
+	a.mu.Lock()
+	alerted := a.alerted[service]
+	delete(a.alerted, service)
+	a.mu.Unlock()
+	if !alerted {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	description := fmt.Sprintf("%s is back on Redis after %s without it", service, downFor.Round(time.Second))
+	a.notify(ctx, "Ops alert: "+service+" back on Redis", description, domain.Metadata{"service": service, "down_for": downFor.String()})
+}
+
+func (a *RedisAlerts) notify(ctx context.Context, subject, body string, metadata domain.Metadata) {
+	admins, err := a.admins.ListAdminIDs(ctx)
+	if err != nil {
+		a.logger.Error("Failed to list alert recipients", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	for _, id := range admins {
+		err := a.notifier.SendRaw(ctx, &notification.Notification{
+			ID:        uuid.New(),
+			UserID:    id,
+			Type:      "OPS_ALERT",
+			Channel:   notification.ChannelEmail,
+			Priority:  notification.PriorityUrgent,
+			Subject:   subject,
+			Body:      body,
+			Metadata:  metadata,
+			CreatedAt: a.now(),
+		})
+		if err != nil {
+			a.logger.Error("Failed to send ops alert", map[string]interface{}{"error": err.Error(), "user_id": id})
+		}
+	}
+}
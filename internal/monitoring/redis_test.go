@@ -0,0 +1,45 @@
+package monitoring
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	events, notifier := &recordingEvents{}, &recordingNotifier{}
+	repo := &fakeMetricRepository{admins: []uuid.UUID{uuid.New(), uuid.New()}}
+	a := NewRedisAlerts(repo, events, notifier, logger.NewNop())
+	a.now = func() time.Time { return now }
+
+	a.RedisRecovered("payment", time.Minute)
+	assert.Empty(t, notifier.sent, "never alerted down")
+
+	a.RedisDown("payment", errors.New("dial tcp: connection refused"))
+	require.Len(t, events.events, 1)
+	assert.Equal(t, domain.SecurityEventTypeRedisDegraded, events.events[0].Type)
+	assert.Equal(t, domain.SecuritySeverityHigh, events.events[0].Severity)
+	require.Len(t, notifier.sent, 2)
+	assert.Contains(t, notifier.sent[0].Subject, "payment running without Redis")
+
+	a.RedisRecovered("payment", 90*time.Second)
+	require.Len(t, notifier.sent, 4)
+	assert.Contains(t, notifier.sent[2].Body, "after 1m30s")
+
+	now = now.Add(time.Minute)
+	a.RedisDown("payment", errors.New("i/o timeout"))
+	assert.Len(t, events.events, 1, "suppressed within the cooldown")
+	a.RedisRecovered("payment", time.Second)
+	assert.Len(t, notifier.sent, 4, "nor its recovery")
+
+	a.RedisDown("wallet", errors.New("i/o timeout"))
+	assert.Len(t, events.events, 2, "services are alerted apart")
+}
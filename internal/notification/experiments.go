@@ -0,0 +1,336 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrExperimentsNotConfigured = errors.New("notification experiments are not configured")
+	ErrInvalidExperiment        = errors.New("invalid notification experiment")
+	ErrExperimentNotFound       = errors.New("notification experiment not found")
+	// ErrExperimentRunning is returned when the event already has a running
+	// experiment.
+	ErrExperimentRunning    = errors.New("event already has a running experiment")
+	ErrExperimentStopped    = errors.New("notification experiment is already stopped")
+	ErrInvalidTrackingEvent = errors.New("tracking event must be opened or clicked")
+)
+
+const maxVariants = 10
+
+var variantKeyPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// TrackingEvent is what a user did with a notification they received.
+type TrackingEvent string
+
+const (
+	TrackOpened  TrackingEvent = "opened"
+	TrackClicked TrackingEvent = "clicked"
+)
+
+// ExperimentRepository stores notification experiments and the
+// notifications sent under them.
+type ExperimentRepository interface {
+	// CreateExperiment returns ErrExperimentRunning when the event already
+	// has a running experiment.
+	CreateExperiment(ctx context.Context, e *domain.NotificationExperiment) error
+	GetExperiment(ctx context.Context, id uuid.UUID) (*domain.NotificationExperiment, error)
+	ListExperiments(ctx context.Context, limit, offset int) ([]domain.NotificationExperiment, int, error)
+	// RunningExperiment returns nil when the event has none.
+	RunningExperiment(ctx context.Context, eventType string) (*domain.NotificationExperiment, error)
+	StopExperiment(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	RecordDelivery(ctx context.Context, d *domain.NotificationDelivery) error
+	// TrackDelivery marks the user's notification opened, or clicked and
+	// opened, keeping the first time of each. It reports false when the
+	// notification was not sent to the user under an experiment.
+	TrackDelivery(ctx context.Context, userID, notificationID uuid.UUID, event TrackingEvent, at time.Time) (bool, error)
+	ExperimentResults(ctx context.Context, id uuid.UUID) ([]domain.NotificationVariantResult, error)
+}
+
+// ExperimentRequest starts an experiment on one kind of notification.
+// Variant weights default to 1.
+type ExperimentRequest struct {
+	Name      string                       `json:"name"`
+	EventType string                       `json:"event_type"`
+	Variants  []domain.NotificationVariant `json:"variants"`
+}
+
+// ExperimentResults sums up an experiment per variant, in the order the
+// variants were given.
+type ExperimentResults struct {
+	Experiment *domain.NotificationExperiment     `json:"experiment"`
+	Variants   []domain.NotificationVariantResult `json:"variants"`
+}
+
+// WithExperiments lets staff test variants of a notification's subject and
+// body against each other.
+func (s *DefaultService) WithExperiments(repo ExperimentRepository) *DefaultService {
+	s.experiments = repo
+	return s
+}
+
+// CreateExperiment starts testing the request's variants on its event. The
+// event must have a template and not be a security alert.
+func (s *DefaultService) CreateExperiment(ctx context.Context, createdBy uuid.UUID, req *ExperimentRequest) (*domain.NotificationExperiment, error) {
+	if s.experiments == nil {
+		return nil, ErrExperimentsNotConfigured
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: name must be 1 to 100 characters", ErrInvalidExperiment)
+	}
+	if _, ok := eventTemplates[req.EventType]; !ok || securityEvents[req.EventType] {
+		return nil, fmt.Errorf("%w: %q is not an event that can be tested", ErrInvalidExperiment, req.EventType)
+	}
+	if len(req.Variants) < 2 || len(req.Variants) > maxVariants {
+		return nil, fmt.Errorf("%w: give 2 to %d variants", ErrInvalidExperiment, maxVariants)
+	}
+	variants := make(domain.NotificationVariants, 0, len(req.Variants))
+	seen := make(map[string]bool, len(req.Variants))
+	for _, v := range req.Variants {
+		if !variantKeyPattern.MatchString(v.Key) {
+			return nil, fmt.Errorf("%w: variant key %q must be 1 to 32 lowercase letters, digits, - or _", ErrInvalidExperiment, v.Key)
+		}
+		if seen[v.Key] {
+			return nil, fmt.Errorf("%w: variant %q is given twice", ErrInvalidExperiment, v.Key)
+		}
+		seen[v.Key] = true
+		if v.Weight == 0 {
+			v.Weight = 1
+		}
+		if v.Weight < 0 || v.Weight > 1000 {
+			return nil, fmt.Errorf("%w: variant %q weight must be 1 to 1000", ErrInvalidExperiment, v.Key)
+		}
+		if len(v.Subject) > 255 {
+			return nil, fmt.Errorf("%w: variant %q subject is longer than 255 characters", ErrInvalidExperiment, v.Key)
+		}
+		if _, _, err := parseVariant(v); err != nil {
+			return nil, fmt.Errorf("%w: variant %q: %v", ErrInvalidExperiment, v.Key, err)
+		}
+		variants = append(variants, v)
+	}
+	e := &domain.NotificationExperiment{
+		ID:        uuid.New(),
+		Name:      name,
+		EventType: req.EventType,
+		Status:    domain.NotificationExperimentRunning,
+		Variants:  variants,
+		CreatedAt: time.Now(),
+	}
+	if createdBy != uuid.Nil {
+		e.CreatedBy = &createdBy
+	}
+	if err := s.experiments.CreateExperiment(ctx, e); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Notification experiment started", map[string]interface{}{
+		"experiment_id": e.ID,
+		"event_type":    e.EventType,
+		"variants":      len(e.Variants),
+	})
+	return e, nil
+}
+
+// Experiments lists experiments, newest first.
+func (s *DefaultService) Experiments(ctx context.Context, limit, offset int) ([]domain.NotificationExperiment, int, error) {
+	if s.experiments == nil {
+		return nil, 0, ErrExperimentsNotConfigured
+	}
+	return s.experiments.ListExperiments(ctx, limit, offset)
+}
+
+// StopExperiment ends an experiment: the event's own template goes to
+// everyone again. Its results are kept.
+func (s *DefaultService) StopExperiment(ctx context.Context, id uuid.UUID) (*domain.NotificationExperiment, error) {
+	if s.experiments == nil {
+		return nil, ErrExperimentsNotConfigured
+	}
+	stopped, err := s.experiments.StopExperiment(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.experiments.GetExperiment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !stopped {
+		return nil, ErrExperimentStopped
+	}
+	return e, nil
+}
+
+// Results sums up the deliveries, opens and clicks of each of the
+// experiment's variants.
+func (s *DefaultService) Results(ctx context.Context, id uuid.UUID) (*ExperimentResults, error) {
+	if s.experiments == nil {
+		return nil, ErrExperimentsNotConfigured
+	}
+	e, err := s.experiments.GetExperiment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.experiments.ExperimentResults(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	byVariant := make(map[string]domain.NotificationVariantResult, len(rows))
+	for _, r := range rows {
+		byVariant[r.Variant] = r
+	}
+	out := &ExperimentResults{Experiment: e, Variants: make([]domain.NotificationVariantResult, 0, len(e.Variants))}
+	for _, v := range e.Variants {
+		r := byVariant[v.Key]
+		r.Variant = v.Key
+		if r.Delivered > 0 {
+			r.OpenRate = float64(r.Opened) / float64(r.Delivered)
+			r.ClickRate = float64(r.Clicked) / float64(r.Delivered)
+		}
+		out.Variants = append(out.Variants, r)
+	}
+	return out, nil
+}
+
+// Track records that the user opened or clicked through from one of their
+// notifications. Notifications not sent under an experiment are ignored, so
+// the app may report every one.
+func (s *DefaultService) Track(ctx context.Context, userID, notificationID uuid.UUID, event TrackingEvent) error {
+	if event != TrackOpened && event != TrackClicked {
+		return ErrInvalidTrackingEvent
+	}
+	if s.experiments == nil {
+		return nil
+	}
+	_, err := s.experiments.TrackDelivery(ctx, userID, notificationID, event, time.Now())
+	return err
+}
+
+// applyExperiment gives the user their variant of the event's subject and
+// body while the event is being tested. It returns a nil experiment, and
+// subject and body unchanged, when it is not or the variant fails to render.
+func (s *DefaultService) applyExperiment(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}, subject, body string) (*domain.NotificationExperiment, string, string, string) {
+	if s.experiments == nil || securityEvents[eventType] {
+		return nil, "", subject, body
+	}
+	if _, ok := eventTemplates[eventType]; !ok {
+		return nil, "", subject, body
+	}
+	e, err := s.experiments.RunningExperiment(ctx, eventType)
+	if err != nil {
+		s.logger.Error("Failed to load notification experiment", map[string]interface{}{
+			"error": err.Error(),
+			"type":  eventType,
+		})
+		return nil, "", subject, body
+	}
+	if e == nil || len(e.Variants) == 0 {
+		return nil, "", subject, body
+	}
+	v := assignVariant(e.ID, userID, e.Variants)
+	vSubject, vBody, err := renderVariant(v, data, subject, body)
+	if err != nil {
+		s.logger.Error("Failed to render notification variant", map[string]interface{}{
+			"error":         err.Error(),
+			"experiment_id": e.ID,
+			"variant":       v.Key,
+			"user_id":       userID,
+		})
+		return nil, "", subject, body
+	}
+	return e, v.Key, vSubject, vBody
+}
+
+// recordDelivery notes that n went out as the variant of the experiment.
+func (s *DefaultService) recordDelivery(ctx context.Context, e *domain.NotificationExperiment, variant string, n *Notification) {
+	err := s.experiments.RecordDelivery(ctx, &domain.NotificationDelivery{
+		NotificationID: n.ID,
+		ExperimentID:   e.ID,
+		Variant:        variant,
+		UserID:         n.UserID,
+		DeliveredAt:    n.CreatedAt,
+	})
+	if err != nil {
+		s.logger.Error("Failed to record notification delivery", map[string]interface{}{
+			"error":           err.Error(),
+			"experiment_id":   e.ID,
+			"notification_id": n.ID,
+		})
+	}
+}
+
+// assignVariant buckets the user into one of the variants by weight. The
+// bucket depends only on the experiment and the user, so users get the same
+// variant every time, and are split afresh for each experiment.
+func assignVariant(experimentID, userID uuid.UUID, variants []domain.NotificationVariant) domain.NotificationVariant {
+	h := sha256.New()
+	h.Write(experimentID[:])
+	h.Write(userID[:])
+	sum := h.Sum(nil)
+
+	var total uint64
+	for _, v := range variants {
+		total += uint64(v.Weight)
+	}
+	if total == 0 {
+		return variants[0]
+	}
+	point := binary.BigEndian.Uint64(sum[:8]) % total
+	for _, v := range variants {
+		if point < uint64(v.Weight) {
+			return v
+		}
+		point -= uint64(v.Weight)
+	}
+	return variants[len(variants)-1]
+}
+
+// parseVariant parses the variant's subject and body templates; either is
+// nil when the variant keeps the event's own.
+func parseVariant(v domain.NotificationVariant) (subject, body *template.Template, err error) {
+	if v.Subject != "" {
+		if subject, err = template.New("subject").Parse(v.Subject); err != nil {
+			return nil, nil, fmt.Errorf("subject: %w", err)
+		}
+	}
+	if v.Body != "" {
+		if body, err = template.New("body").Parse(v.Body); err != nil {
+			return nil, nil, fmt.Errorf("body: %w", err)
+		}
+	}
+	return subject, body, nil
+}
+
+// renderVariant fills in the variant's subject and body, keeping subject
+// and body, the event's own, where the variant has none.
+func renderVariant(v domain.NotificationVariant, data map[string]interface{}, subject, body string) (string, string, error) {
+	st, bt, err := parseVariant(v)
+	if err != nil {
+		return "", "", err
+	}
+	var buf bytes.Buffer
+	if st != nil {
+		if err := st.Execute(&buf, data); err != nil {
+			return "", "", fmt.Errorf("render variant %s subject: %w", v.Key, err)
+		}
+		subject = buf.String()
+		buf.Reset()
+	}
+	if bt != nil {
+		if err := bt.Execute(&buf, data); err != nil {
+			return "", "", fmt.Errorf("render variant %s body: %w", v.Key, err)
+		}
+		body = buf.String()
+	}
+	return subject, body, nil
+}
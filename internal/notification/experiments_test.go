@@ -0,0 +1,268 @@
+package notification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryExperiments keeps experiments and their deliveries in memory.
+type memoryExperiments struct {
+	experiments []*domain.NotificationExperiment
+	deliveries  []*domain.NotificationDelivery
+}
+
+func (m *memoryExperiments) CreateExperiment(_ context.Context, e *domain.NotificationExperiment) error {
+	for _, old := range m.experiments {
+		if old.EventType == e.EventType && old.Status == domain.NotificationExperimentRunning {
+			return ErrExperimentRunning
+		}
+	}
+	m.experiments = append(m.experiments, e)
+	return nil
+}
+
+func (m *memoryExperiments) GetExperiment(_ context.Context, id uuid.UUID) (*domain.NotificationExperiment, error) {
+	for _, e := range m.experiments {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, ErrExperimentNotFound
+}
+
+func (m *memoryExperiments) ListExperiments(_ context.Context, limit, offset int) ([]domain.NotificationExperiment, int, error) {
+	var out []domain.NotificationExperiment
+	for i := len(m.experiments) - 1; i >= 0; i-- {
+		out = append(out, *m.experiments[i])
+	}
+	return out, len(out), nil
+}
+
+func (m *memoryExperiments) RunningExperiment(_ context.Context, eventType string) (*domain.NotificationExperiment, error) {
+	for _, e := range m.experiments {
+		if e.EventType == eventType && e.Status == domain.NotificationExperimentRunning {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryExperiments) StopExperiment(_ context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	for _, e := range m.experiments {
+		if e.ID == id && e.Status == domain.NotificationExperimentRunning {
+			e.Status = domain.NotificationExperimentStopped
+			e.StoppedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryExperiments) RecordDelivery(_ context.Context, d *domain.NotificationDelivery) error {
+	m.deliveries = append(m.deliveries, d)
+	return nil
+}
+
+func (m *memoryExperiments) TrackDelivery(_ context.Context, userID, notificationID uuid.UUID, event TrackingEvent, at time.Time) (bool, error) {
+	for _, d := range m.deliveries {
+		if d.NotificationID == notificationID && d.UserID == userID {
+			if d.OpenedAt == nil {
+				d.OpenedAt = &at
+			}
+			if event == TrackClicked && d.ClickedAt == nil {
+				d.ClickedAt = &at
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryExperiments) ExperimentResults(_ context.Context, id uuid.UUID) ([]domain.NotificationVariantResult, error) {
+	byVariant := make(map[string]*domain.NotificationVariantResult)
+	users := make(map[string]map[uuid.UUID]bool)
+	var out []domain.NotificationVariantResult
+	for _, d := range m.deliveries {
+		if d.ExperimentID != id {
+			continue
+		}
+		r, ok := byVariant[d.Variant]
+		if !ok {
+			r = &domain.NotificationVariantResult{Variant: d.Variant}
+			byVariant[d.Variant] = r
+			users[d.Variant] = make(map[uuid.UUID]bool)
+		}
+		users[d.Variant][d.UserID] = true
+		r.Users = len(users[d.Variant])
+		r.Delivered++
+		if d.OpenedAt != nil {
+			r.Opened++
+		}
+		if d.ClickedAt != nil {
+			r.Clicked++
+		}
+	}
+	for _, r := range byVariant {
+		out = append(out, *r)
+	}
+	return out, nil
+}
+
+func paymentReceivedExperiment() *ExperimentRequest {
+	return &ExperimentRequest{
+		Name:      "Payment received copy",
+		EventType: "PAYMENT_RECEIVED",
+		Variants: []domain.NotificationVariant{
+			{Key: "control"},
+			{Key: "cheerful", Subject: "Money in!", Body: "{{.sender_name}} just sent you {{.amount}} {{.currency}}. Tap to see it."},
+		},
+	}
+}
+
+func TestAssignVariantIsDeterministicAndWeighted(t *testing.T) {
+	experimentID := uuid.New()
+	variants := []domain.NotificationVariant{{Key: "a", Weight: 3}, {Key: "b", Weight: 1}}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		userID := uuid.New()
+		v := assignVariant(experimentID, userID, variants)
+		assert.Equal(t, v.Key, assignVariant(experimentID, userID, variants).Key)
+		counts[v.Key]++
+	}
+	assert.InDelta(t, 3000, counts["a"], 200)
+	assert.InDelta(t, 1000, counts["b"], 200)
+}
+
+func TestNotifySendsUsersTheirVariant(t *testing.T) {
+	ctx := context.Background()
+	svc, sender, _ := newPushService(t)
+	experiments := &memoryExperiments{}
+	svc.WithExperiments(experiments)
+
+	e, err := svc.CreateExperiment(ctx, uuid.New(), paymentReceivedExperiment())
+	require.NoError(t, err)
+	assert.Equal(t, 1, e.Variants[0].Weight)
+	_, err = svc.CreateExperiment(ctx, uuid.New(), paymentReceivedExperiment())
+	assert.ErrorIs(t, err, ErrExperimentRunning)
+
+	data := map[string]interface{}{"amount": "25.00", "currency": "MWK", "sender_name": "Alice"}
+	seen := make(map[string]bool)
+	for i := 0; i < 40; i++ {
+		userID := uuid.New()
+		token := userID.String()
+		_, err := svc.RegisterPushToken(ctx, userID, domain.PushProviderFCM, token, "")
+		require.NoError(t, err)
+		require.NoError(t, svc.Notify(ctx, userID, "PAYMENT_RECEIVED", data))
+		require.NoError(t, svc.Notify(ctx, userID, "PAYMENT_RECEIVED", data))
+
+		variant := assignVariant(e.ID, userID, e.Variants).Key
+		seen[variant] = true
+		msgs := sender.sent[token]
+		require.Len(t, msgs, 2)
+		for _, msg := range msgs {
+			if variant == "control" {
+				assert.Equal(t, "Payment Received", msg.Title)
+				assert.Equal(t, "You received 25.00 MWK from Alice.", msg.Body)
+			} else {
+				assert.Equal(t, "Money in!", msg.Title)
+				assert.Equal(t, "Alice just sent you 25.00 MWK. Tap to see it.", msg.Body)
+			}
+		}
+	}
+	assert.True(t, seen["control"] && seen["cheerful"])
+	require.Len(t, experiments.deliveries, 80)
+
+	// Other events are not part of the experiment
+	require.NoError(t, svc.Notify(ctx, uuid.New(), "PAYMENT_SENT", data))
+	assert.Len(t, experiments.deliveries, 80)
+
+	_, err = svc.StopExperiment(ctx, e.ID)
+	require.NoError(t, err)
+	_, err = svc.StopExperiment(ctx, e.ID)
+	assert.ErrorIs(t, err, ErrExperimentStopped)
+	require.NoError(t, svc.Notify(ctx, uuid.New(), "PAYMENT_RECEIVED", data))
+	assert.Len(t, experiments.deliveries, 80)
+}
+
+func TestExperimentResults(t *testing.T) {
+	ctx := context.Background()
+	experiments := &memoryExperiments{}
+	svc := NewService(logger.NewNop(), nil, nil).WithExperiments(experiments)
+	e, err := svc.CreateExperiment(ctx, uuid.Nil, paymentReceivedExperiment())
+	require.NoError(t, err)
+
+	// Find a user in each variant
+	users := make(map[string]uuid.UUID)
+	for len(users) < 2 {
+		id := uuid.New()
+		users[assignVariant(e.ID, id, e.Variants).Key] = id
+	}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, svc.Notify(ctx, users["control"], "PAYMENT_RECEIVED", nil))
+	}
+	for i := 0; i < 4; i++ {
+		require.NoError(t, svc.Notify(ctx, users["cheerful"], "PAYMENT_RECEIVED", nil))
+	}
+	var control, cheerful []uuid.UUID
+	for _, d := range experiments.deliveries {
+		if d.Variant == "control" {
+			control = append(control, d.NotificationID)
+		} else {
+			cheerful = append(cheerful, d.NotificationID)
+		}
+	}
+	require.NoError(t, svc.Track(ctx, users["control"], control[0], TrackOpened))
+	require.NoError(t, svc.Track(ctx, users["cheerful"], cheerful[0], TrackClicked))
+	require.NoError(t, svc.Track(ctx, users["cheerful"], cheerful[1], TrackOpened))
+	require.NoError(t, svc.Track(ctx, users["cheerful"], cheerful[1], TrackOpened))
+	// Someone else's notification and ones not under test are ignored
+	require.NoError(t, svc.Track(ctx, users["control"], cheerful[2], TrackClicked))
+	require.NoError(t, svc.Track(ctx, users["control"], uuid.New(), TrackOpened))
+	assert.ErrorIs(t, svc.Track(ctx, users["control"], control[1], "dismissed"), ErrInvalidTrackingEvent)
+
+	results, err := svc.Results(ctx, e.ID)
+	require.NoError(t, err)
+	require.Len(t, results.Variants, 2)
+	assert.Equal(t, domain.NotificationVariantResult{
+		Variant: "control", Users: 1, Delivered: 2, Opened: 1, Clicked: 0, OpenRate: 0.5, ClickRate: 0,
+	}, results.Variants[0])
+	assert.Equal(t, domain.NotificationVariantResult{
+		Variant: "cheerful", Users: 1, Delivered: 4, Opened: 2, Clicked: 1, OpenRate: 0.5, ClickRate: 0.25,
+	}, results.Variants[1])
+
+	_, err = svc.Results(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrExperimentNotFound)
+}
+
+func TestCreateExperimentValidates(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(logger.NewNop(), nil, nil).WithExperiments(&memoryExperiments{})
+
+	for name, mutate := range map[string]func(*ExperimentRequest){
+		"no name":        func(r *ExperimentRequest) { r.Name = " " },
+		"unknown event":  func(r *ExperimentRequest) { r.EventType = "NOPE" },
+		"security alert": func(r *ExperimentRequest) { r.EventType = "LOGIN_NEW_DEVICE" },
+		"one variant":    func(r *ExperimentRequest) { r.Variants = r.Variants[:1] },
+		"duplicate key":  func(r *ExperimentRequest) { r.Variants[1].Key = "control" },
+		"bad key":        func(r *ExperimentRequest) { r.Variants[1].Key = "Big Font" },
+		"bad weight":     func(r *ExperimentRequest) { r.Variants[1].Weight = -1 },
+		"bad template":   func(r *ExperimentRequest) { r.Variants[1].Body = "{{.amount" },
+	} {
+		req := paymentReceivedExperiment()
+		mutate(req)
+		_, err := svc.CreateExperiment(ctx, uuid.Nil, req)
+		assert.ErrorIs(t, err, ErrInvalidExperiment, name)
+	}
+
+	_, err := NewService(logger.NewNop(), nil, nil).CreateExperiment(ctx, uuid.Nil, paymentReceivedExperiment())
+	assert.ErrorIs(t, err, ErrExperimentsNotConfigured)
+}
@@ -0,0 +1,133 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPreferencesNotConfigured = errors.New("notification preferences are not configured")
+	ErrInvalidPreference        = errors.New("invalid notification preference")
+)
+
+// PreferenceRepository stores the channels users turned off or on per kind
+// of notification.
+type PreferenceRepository interface {
+	ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]domain.NotificationPreference, error)
+	SetNotificationPreferences(ctx context.Context, userID uuid.UUID, prefs []domain.NotificationPreference) error
+}
+
+// PreferenceEvents are the kinds of notification users may choose channels
+// for. Security alerts are not among them: they always go out.
+var PreferenceEvents = []string{
+	"PAYMENT_SENT",
+	"PAYMENT_RECEIVED",
+	"KYC_STATUS_CHANGED",
+	"BROADCAST",
+}
+
+// EventPreferences are the channels on for one kind of notification.
+type EventPreferences struct {
+	EventType string                              `json:"event_type"`
+	Channels  map[domain.NotificationChannel]bool `json:"channels"`
+}
+
+// WithPreferences lets users turn channels off per kind of notification.
+func (s *DefaultService) WithPreferences(repo PreferenceRepository) *DefaultService {
+	s.preferences = repo
+	return s
+}
+
+// Preferences returns the user's channels for every kind of notification
+// they may choose for.
+func (s *DefaultService) Preferences(ctx context.Context, userID uuid.UUID) ([]EventPreferences, error) {
+	var stored []domain.NotificationPreference
+	if s.preferences != nil {
+		var err error
+		if stored, err = s.preferences.ListNotificationPreferences(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	prefs := preferenceSet(stored)
+	out := make([]EventPreferences, 0, len(PreferenceEvents))
+	for _, event := range PreferenceEvents {
+		e := EventPreferences{EventType: event, Channels: make(map[domain.NotificationChannel]bool)}
+		for _, ch := range domain.NotificationChannels {
+			e.Channels[ch] = prefs.enabled(event, ch)
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// SetPreferences turns channels on or off for kinds of notification,
+// leaving the others as they were.
+func (s *DefaultService) SetPreferences(ctx context.Context, userID uuid.UUID, prefs []domain.NotificationPreference) ([]EventPreferences, error) {
+	if s.preferences == nil {
+		return nil, ErrPreferencesNotConfigured
+	}
+	for _, p := range prefs {
+		if !isPreferenceEvent(p.EventType) {
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidPreference, p.EventType)
+		}
+		if !isNotificationChannel(p.Channel) {
+			return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidPreference, p.Channel)
+		}
+	}
+	if err := s.preferences.SetNotificationPreferences(ctx, userID, prefs); err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, userID)
+}
+
+// preferencesFor loads the preferences of n's user, unless n is a security
+// alert, which ignores them. On failure every channel stays on.
+func (s *DefaultService) preferencesFor(ctx context.Context, n *Notification) preferenceSet {
+	if s.preferences == nil || securityEvents[n.Type] {
+		return nil
+	}
+	stored, err := s.preferences.ListNotificationPreferences(ctx, n.UserID)
+	if err != nil {
+		s.logger.Error("Failed to load notification preferences", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": n.UserID,
+		})
+		return nil
+	}
+	return preferenceSet(stored)
+}
+
+// preferenceSet holds the channels a user set; the rest are on.
+type preferenceSet []domain.NotificationPreference
+
+func (p preferenceSet) enabled(eventType string, ch domain.NotificationChannel) bool {
+	for _, pref := range p {
+		if pref.EventType == eventType && pref.Channel == ch {
+			return pref.Enabled
+		}
+	}
+	return true
+}
+
+func isPreferenceEvent(eventType string) bool {
+	for _, e := range PreferenceEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotificationChannel(ch domain.NotificationChannel) bool {
+	for _, c := range domain.NotificationChannels {
+		if c == ch {
+			return true
+		}
+	}
+	return false
+}
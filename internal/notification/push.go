@@ -0,0 +1,202 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+	"kyd/pkg/push"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPushNotConfigured   = errors.New("push notifications are not configured")
+	ErrUnsupportedProvider = errors.New("push provider is not supported")
+	ErrInvalidPushToken    = errors.New("push token must be 1 to 4096 characters")
+	ErrPushTokenNotFound   = errors.New("push token not found")
+)
+
+const maxPushTokenLength = 4096
+
+// PushTokenRepository stores the devices registered for push. The methods
+// taking a user only touch that user's tokens.
+type PushTokenRepository interface {
+	// SavePushToken registers the token for its user, taking it over from
+	// whoever had it.
+	SavePushToken(ctx context.Context, t *domain.PushToken) error
+	ListPushTokens(ctx context.Context, userID uuid.UUID) ([]domain.PushToken, error)
+	DeletePushToken(ctx context.Context, userID, id uuid.UUID) (bool, error)
+}
+
+// WithPush delivers notifications to the devices users register, through
+// the sender of each device's provider.
+func (s *DefaultService) WithPush(senders map[domain.PushProvider]push.Sender, tokens PushTokenRepository) *DefaultService {
+	s.pushers = senders
+	s.pushTokens = tokens
+	return s
+}
+
+// PushSenders builds a sender for each provider. Providers without
+// credentials log their pushes instead of sending them.
+func PushSenders(ctx context.Context, cfg config.PushConfig, log logger.Logger) (map[domain.PushProvider]push.Sender, error) {
+	senders := map[domain.PushProvider]push.Sender{
+		domain.PushProviderFCM:  push.NewLogSender(log),
+		domain.PushProviderAPNs: push.NewLogSender(log),
+	}
+	if cfg.FCMCredentialsFile != "" {
+		creds, err := os.ReadFile(cfg.FCMCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read fcm credentials: %w", err)
+		}
+		fcm, err := push.NewFCMSender(ctx, cfg.FCMProjectID, creds)
+		if err != nil {
+			return nil, err
+		}
+		senders[domain.PushProviderFCM] = fcm
+	}
+	if cfg.APNsKeyFile != "" {
+		key, err := os.ReadFile(cfg.APNsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read apns key: %w", err)
+		}
+		apns, err := push.NewAPNsSender(key, cfg.APNsKeyID, cfg.APNsTeamID, cfg.APNsTopic, cfg.APNsProduction)
+		if err != nil {
+			return nil, err
+		}
+		senders[domain.PushProviderAPNs] = apns
+	}
+	return senders, nil
+}
+
+// RegisterPushToken lets the user's device receive their push
+// notifications. Registering a known token again refreshes it.
+func (s *DefaultService) RegisterPushToken(ctx context.Context, userID uuid.UUID, provider domain.PushProvider, token, deviceName string) (*domain.PushToken, error) {
+	if s.pushTokens == nil {
+		return nil, ErrPushNotConfigured
+	}
+	if _, ok := s.pushers[provider]; !ok {
+		return nil, ErrUnsupportedProvider
+	}
+	token = strings.TrimSpace(token)
+	if token == "" || len(token) > maxPushTokenLength {
+		return nil, ErrInvalidPushToken
+	}
+	deviceName = strings.TrimSpace(deviceName)
+	if len([]rune(deviceName)) > 100 {
+		deviceName = string([]rune(deviceName)[:100])
+	}
+	now := time.Now()
+	t := &domain.PushToken{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Provider:   provider,
+		Token:      token,
+		DeviceName: deviceName,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.pushTokens.SavePushToken(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// PushTokens lists the devices registered for the user's push
+// notifications.
+func (s *DefaultService) PushTokens(ctx context.Context, userID uuid.UUID) ([]domain.PushToken, error) {
+	if s.pushTokens == nil {
+		return nil, ErrPushNotConfigured
+	}
+	return s.pushTokens.ListPushTokens(ctx, userID)
+}
+
+// UnregisterPushToken stops pushing to one of the user's devices, as when
+// they sign out of the app.
+func (s *DefaultService) UnregisterPushToken(ctx context.Context, userID, id uuid.UUID) error {
+	if s.pushTokens == nil {
+		return ErrPushNotConfigured
+	}
+	ok, err := s.pushTokens.DeletePushToken(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPushTokenNotFound
+	}
+	return nil
+}
+
+// wantsPush reports whether n goes to the user's devices: when asked for,
+// and for security alerts and events whose template pushes.
+func (s *DefaultService) wantsPush(n *Notification) bool {
+	if s.pushTokens == nil {
+		return false
+	}
+	if n.Channel == ChannelPush || n.hasChannel(ChannelPush) || securityEvents[n.Type] {
+		return true
+	}
+	t, ok := eventTemplates[n.Type]
+	return ok && t.push
+}
+
+// sendPush delivers n to each of the user's devices. Tokens the provider no
+// longer accepts are forgotten.
+func (s *DefaultService) sendPush(ctx context.Context, n *Notification) {
+	tokens, err := s.pushTokens.ListPushTokens(ctx, n.UserID)
+	if err != nil {
+		s.logger.Error("Failed to load push tokens", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": n.UserID,
+		})
+		return
+	}
+	msg := pushMessage(n)
+	for _, t := range tokens {
+		sender, ok := s.pushers[t.Provider]
+		if !ok {
+			continue
+		}
+		err := sender.Send(ctx, t.Token, msg)
+		if errors.Is(err, push.ErrUnregistered) {
+			if _, err := s.pushTokens.DeletePushToken(ctx, t.UserID, t.ID); err != nil {
+				s.logger.Error("Failed to forget stale push token", map[string]interface{}{
+					"error":    err.Error(),
+					"token_id": t.ID,
+				})
+			}
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to send push notification", map[string]interface{}{
+				"error":           err.Error(),
+				"user_id":         n.UserID,
+				"notification_id": n.ID,
+				"provider":        t.Provider,
+			})
+		}
+	}
+}
+
+// pushMessage is the push payload for n. Its data tells the app the kind
+// of notification and carries the fields the event's template names.
+func pushMessage(n *Notification) *push.Message {
+	data := map[string]string{"type": n.Type}
+	if n.ID != uuid.Nil {
+		data["notification_id"] = n.ID.String()
+	}
+	if t, ok := eventTemplates[n.Type]; ok {
+		for _, key := range t.pushData {
+			if v, ok := n.Metadata[key]; ok && v != nil {
+				data[key] = fmt.Sprint(v)
+			}
+		}
+	}
+	return &push.Message{Title: n.Subject, Body: n.Body, Data: data}
+}
@@ -0,0 +1,182 @@
+package notification
+
+import (
+	"context"
+	"testing"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+	"kyd/pkg/push"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender records the pushes it is given; tokens in stale are refused as
+// unregistered.
+type fakeSender struct {
+	sent  map[string][]*push.Message
+	stale map[string]bool
+}
+
+func (f *fakeSender) Send(_ context.Context, token string, msg *push.Message) error {
+	if f.stale[token] {
+		return push.ErrUnregistered
+	}
+	if f.sent == nil {
+		f.sent = make(map[string][]*push.Message)
+	}
+	f.sent[token] = append(f.sent[token], msg)
+	return nil
+}
+
+// memorySettings keeps push tokens and preferences in memory.
+type memorySettings struct {
+	tokens []domain.PushToken
+	prefs  map[uuid.UUID][]domain.NotificationPreference
+}
+
+func (m *memorySettings) SavePushToken(_ context.Context, t *domain.PushToken) error {
+	m.tokens = append(m.tokens, *t)
+	return nil
+}
+
+func (m *memorySettings) ListPushTokens(_ context.Context, userID uuid.UUID) ([]domain.PushToken, error) {
+	var out []domain.PushToken
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (m *memorySettings) DeletePushToken(_ context.Context, userID, id uuid.UUID) (bool, error) {
+	for i, t := range m.tokens {
+		if t.UserID == userID && t.ID == id {
+			m.tokens = append(m.tokens[:i], m.tokens[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memorySettings) ListNotificationPreferences(_ context.Context, userID uuid.UUID) ([]domain.NotificationPreference, error) {
+	return m.prefs[userID], nil
+}
+
+func (m *memorySettings) SetNotificationPreferences(_ context.Context, userID uuid.UUID, prefs []domain.NotificationPreference) error {
+	if m.prefs == nil {
+		m.prefs = make(map[uuid.UUID][]domain.NotificationPreference)
+	}
+	for _, p := range prefs {
+		replaced := false
+		for i, old := range m.prefs[userID] {
+			if old.EventType == p.EventType && old.Channel == p.Channel {
+				m.prefs[userID][i] = p
+				replaced = true
+			}
+		}
+		if !replaced {
+			m.prefs[userID] = append(m.prefs[userID], p)
+		}
+	}
+	return nil
+}
+
+func newPushService(t *testing.T) (*DefaultService, *fakeSender, *memorySettings) {
+	t.Helper()
+	sender := &fakeSender{stale: make(map[string]bool)}
+	settings := &memorySettings{}
+	svc := NewService(logger.NewNop(), nil, nil).
+		WithPreferences(settings).
+		WithPush(map[domain.PushProvider]push.Sender{domain.PushProviderFCM: sender}, settings)
+	return svc, sender, settings
+}
+
+func TestPushRendersEventTemplate(t *testing.T) {
+	ctx := context.Background()
+	svc, sender, _ := newPushService(t)
+	userID := uuid.New()
+	_, err := svc.RegisterPushToken(ctx, userID, domain.PushProviderFCM, "device-1", "Pixel")
+	require.NoError(t, err)
+
+	txID := uuid.New().String()
+	require.NoError(t, svc.Notify(ctx, userID, "PAYMENT_RECEIVED", map[string]interface{}{
+		"transaction_id": txID,
+		"amount":         "25.00",
+		"currency":       "MWK",
+		"sender_name":    "Alice",
+	}))
+	require.NoError(t, svc.Notify(ctx, userID, "KYC_STATUS_CHANGED", map[string]interface{}{
+		"status": "rejected",
+		"reason": "document expired",
+	}))
+
+	require.Len(t, sender.sent["device-1"], 2)
+	payment := sender.sent["device-1"][0]
+	assert.Equal(t, "Payment Received", payment.Title)
+	assert.Equal(t, "You received 25.00 MWK from Alice.", payment.Body)
+	assert.Equal(t, "PAYMENT_RECEIVED", payment.Data["type"])
+	assert.Equal(t, txID, payment.Data["transaction_id"])
+	assert.NotEmpty(t, payment.Data["notification_id"])
+
+	kyc := sender.sent["device-1"][1]
+	assert.Equal(t, "Identity Not Verified", kyc.Title)
+	assert.Contains(t, kyc.Body, "document expired")
+	assert.Equal(t, "rejected", kyc.Data["status"])
+
+	_, err = svc.RegisterPushToken(ctx, userID, "webpush", "device-2", "")
+	assert.ErrorIs(t, err, ErrUnsupportedProvider)
+}
+
+func TestPushFollowsPreferences(t *testing.T) {
+	ctx := context.Background()
+	svc, sender, _ := newPushService(t)
+	userID := uuid.New()
+	_, err := svc.RegisterPushToken(ctx, userID, domain.PushProviderFCM, "device-1", "")
+	require.NoError(t, err)
+
+	prefs, err := svc.SetPreferences(ctx, userID, []domain.NotificationPreference{
+		{EventType: "PAYMENT_SENT", Channel: domain.NotificationChannelPush, Enabled: false},
+	})
+	require.NoError(t, err)
+	for _, p := range prefs {
+		assert.Equal(t, p.EventType != "PAYMENT_SENT", p.Channels[domain.NotificationChannelPush], p.EventType)
+		assert.True(t, p.Channels[domain.NotificationChannelSMS], p.EventType)
+	}
+
+	require.NoError(t, svc.Notify(ctx, userID, "PAYMENT_SENT", map[string]interface{}{"amount": "1", "currency": "MWK"}))
+	assert.Empty(t, sender.sent["device-1"])
+
+	// Security alerts go out whatever the user chose
+	require.NoError(t, svc.Notify(ctx, userID, "LOGIN_NEW_DEVICE", map[string]interface{}{"device_name": "Laptop"}))
+	assert.Len(t, sender.sent["device-1"], 1)
+
+	_, err = svc.SetPreferences(ctx, userID, []domain.NotificationPreference{
+		{EventType: "LOGIN_NEW_DEVICE", Channel: domain.NotificationChannelPush, Enabled: false},
+	})
+	assert.ErrorIs(t, err, ErrInvalidPreference)
+}
+
+func TestPushForgetsUnregisteredTokens(t *testing.T) {
+	ctx := context.Background()
+	svc, sender, settings := newPushService(t)
+	userID := uuid.New()
+	_, err := svc.RegisterPushToken(ctx, userID, domain.PushProviderFCM, "old-install", "")
+	require.NoError(t, err)
+	_, err = svc.RegisterPushToken(ctx, userID, domain.PushProviderFCM, "new-install", "")
+	require.NoError(t, err)
+	sender.stale["old-install"] = true
+
+	require.NoError(t, svc.Notify(ctx, userID, "RISK_ALERT", map[string]interface{}{"reason": "unusual amount"}))
+
+	assert.Len(t, sender.sent["new-install"], 1)
+	require.Len(t, settings.tokens, 1)
+	assert.Equal(t, "new-install", settings.tokens[0].Token)
+
+	assert.ErrorIs(t, svc.UnregisterPushToken(ctx, uuid.New(), settings.tokens[0].ID), ErrPushTokenNotFound)
+	assert.NoError(t, svc.UnregisterPushToken(ctx, userID, settings.tokens[0].ID))
+	assert.Empty(t, settings.tokens)
+}
@@ -10,6 +10,8 @@ import (
 
 	"kyd/internal/domain"
 	"kyd/pkg/logger"
+	"kyd/pkg/push"
+	"kyd/pkg/sms"
 
 	"github.com/google/uuid"
 )
@@ -24,6 +26,11 @@ type Repository interface {
 	Create(ctx context.Context, n *domain.Notification) error
 }
 
+// UserFinder looks up the phone number and its verification state for SMS delivery.
+type UserFinder interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
 // ChannelType represents the delivery method (Email, SMS, Push).
 type ChannelType string
 
@@ -49,6 +56,7 @@ type Notification struct {
 	UserID    uuid.UUID
 	Type      string // e.g., "PAYMENT_RECEIVED", "LOGIN_ALERT"
 	Channel   ChannelType
+	Channels  []ChannelType // additional delivery channels
 	Priority  Priority
 	Subject   string
 	Body      string
@@ -69,7 +77,13 @@ type DefaultService struct {
 	repo      Repository
 	// In a real system, we'd have providers here (e.g., SendGrid, Twilio)
 	// For now, we simulate them.
-	mu sync.Mutex
+	sms         sms.Sender
+	users       UserFinder
+	pushers     map[domain.PushProvider]push.Sender
+	pushTokens  PushTokenRepository
+	preferences PreferenceRepository
+	experiments ExperimentRepository
+	mu          sync.Mutex
 }
 
 // NewService creates a new notification service.
@@ -81,45 +95,33 @@ func NewService(log logger.Logger, auditRepo AuditRepository, repo Repository) *
 	}
 }
 
-// Notify constructs and sends a notification based on an event type.
+// WithSMS delivers urgent notifications by text message. Messages are only
+// sent to phone numbers the user has verified. A nil sender keeps SMS simulated.
+func (s *DefaultService) WithSMS(sender sms.Sender, users UserFinder) *DefaultService {
+	s.sms = sender
+	s.users = users
+	return s
+}
+
+// Notify renders the event's template into a notification and sends it.
+// While the event is being tested, the user gets their variant of it.
 func (s *DefaultService) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
-	// Template logic would go here. For now, we hardcode a few templates.
-	var subject, body string
-	var priority Priority = PriorityNormal
-
-	switch eventType {
-	case "PAYMENT_SENT":
-		amount := data["amount"]
-		currency := data["currency"]
-		receiver := data["receiver_name"]
-		subject = "Payment Sent"
-		body = fmt.Sprintf("You sent %v %v to %v.", amount, currency, receiver)
-		priority = PriorityHigh
-
-	case "PAYMENT_RECEIVED":
-		amount := data["amount"]
-		currency := data["currency"]
-		sender := data["sender_name"]
-		subject = "Payment Received"
-		body = fmt.Sprintf("You received %v %v from %v.", amount, currency, sender)
-		priority = PriorityHigh
-
-	case "LOGIN_NEW_DEVICE":
-		device := data["device_name"]
-		location := data["location"]
-		subject = "New Login Detected"
-		body = fmt.Sprintf("New login from %v near %v. If this wasn't you, freeze your account immediately.", device, location)
-		priority = PriorityUrgent
-
-	case "RISK_ALERT":
-		reason := data["reason"]
-		subject = "Security Alert"
-		body = fmt.Sprintf("Your transaction was flagged: %v. Please contact support.", reason)
-		priority = PriorityUrgent
-
-	default:
-		subject = "Notification"
-		body = fmt.Sprintf("Event: %s", eventType)
+	subject, body, priority, err := render(eventType, data)
+	if err != nil {
+		s.logger.Error("Failed to render notification", map[string]interface{}{
+			"error":   err.Error(),
+			"type":    eventType,
+			"user_id": userID,
+		})
+		subject, body, priority = "Notification", fmt.Sprintf("Event: %s", eventType), PriorityNormal
+	}
+
+	var (
+		experiment *domain.NotificationExperiment
+		variant    string
+	)
+	if err == nil {
+		experiment, variant, subject, body = s.applyExperiment(ctx, userID, eventType, data, subject, body)
 	}
 
 	n := &Notification{
@@ -134,7 +136,13 @@ func (s *DefaultService) Notify(ctx context.Context, userID uuid.UUID, eventType
 		CreatedAt: time.Now(),
 	}
 
-	return s.SendRaw(ctx, n)
+	if err := s.SendRaw(ctx, n); err != nil {
+		return err
+	}
+	if experiment != nil {
+		s.recordDelivery(ctx, experiment, variant, n)
+	}
+	return nil
 }
 
 // SendRaw handles the actual delivery simulation.
@@ -149,17 +157,22 @@ func (s *DefaultService) SendRaw(ctx context.Context, n *Notification) error {
 		"notification_id": n.ID,
 		"user_id":         n.UserID,
 		"channel":         n.Channel,
+		"channels":        n.Channels,
 		"type":            n.Type,
 		"subject":         n.Subject,
 		"priority":        n.Priority,
 	})
 
-	// If it's urgent, simulate SMS as well
-	if n.Priority == PriorityUrgent {
-		s.logger.Info("SMS Sent (Urgent)", map[string]interface{}{
-			"user_id": n.UserID,
-			"body":    n.Body,
-		})
+	prefs := s.preferencesFor(ctx, n)
+
+	// If it's urgent or explicitly requested, send SMS as well
+	if (n.Priority == PriorityUrgent || n.Channel == ChannelSMS || n.hasChannel(ChannelSMS)) &&
+		prefs.enabled(n.Type, domain.NotificationChannelSMS) {
+		s.sendSMS(ctx, n)
+	}
+
+	if s.wantsPush(n) && prefs.enabled(n.Type, domain.NotificationChannelPush) {
+		s.sendPush(ctx, n)
 	}
 
 	// Create Audit Log
@@ -236,3 +249,54 @@ func (s *DefaultService) SendRaw(ctx context.Context, n *Notification) error {
 
 	return nil
 }
+
+func (n *Notification) hasChannel(c ChannelType) bool {
+	for _, ch := range n.Channels {
+		if ch == c {
+			return true
+		}
+	}
+	return false
+}
+
+// sendSMS texts the notification body to the user's phone. When a user
+// lookup is configured, unverified numbers are skipped: they may belong to
+// someone else.
+func (s *DefaultService) sendSMS(ctx context.Context, n *Notification) {
+	if s.users == nil {
+		s.logger.Info("SMS Sent (Urgent)", map[string]interface{}{
+			"user_id": n.UserID,
+			"body":    n.Body,
+		})
+		return
+	}
+	user, err := s.users.FindByID(ctx, n.UserID)
+	if err != nil {
+		s.logger.Error("Failed to load user for SMS", map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": n.UserID,
+		})
+		return
+	}
+	if !user.PhoneVerified || user.Phone == "" {
+		s.logger.Info("SMS skipped: phone not verified", map[string]interface{}{
+			"user_id":         n.UserID,
+			"notification_id": n.ID,
+		})
+		return
+	}
+	if s.sms == nil {
+		s.logger.Info("SMS Sent (Urgent)", map[string]interface{}{
+			"user_id": n.UserID,
+			"body":    n.Body,
+		})
+		return
+	}
+	if err := s.sms.Send(ctx, user.Phone, n.Body); err != nil {
+		s.logger.Error("Failed to send SMS", map[string]interface{}{
+			"error":           err.Error(),
+			"user_id":         n.UserID,
+			"notification_id": n.ID,
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// eventTemplate renders one kind of event into a notification. The same
+// subject and body go to every channel; a push carries the pushData fields
+// of the event so the app can open what it is about.
+type eventTemplate struct {
+	subject  *template.Template
+	body     *template.Template
+	priority Priority
+	// push sends the notification to the user's devices unless they turned
+	// push off for the event
+	push     bool
+	pushData []string
+}
+
+func newEventTemplate(subject, body string, priority Priority, push bool, pushData ...string) *eventTemplate {
+	return &eventTemplate{
+		subject:  template.Must(template.New("subject").Parse(subject)),
+		body:     template.Must(template.New("body").Parse(body)),
+		priority: priority,
+		push:     push,
+		pushData: pushData,
+	}
+}
+
+var eventTemplates = map[string]*eventTemplate{
+	"PAYMENT_SENT": newEventTemplate("Payment Sent",
+		"You sent {{.amount}} {{.currency}} to {{.receiver_name}}.",
+		PriorityHigh, true, "transaction_id"),
+	"PAYMENT_RECEIVED": newEventTemplate("Payment Received",
+		"You received {{.amount}} {{.currency}} from {{.sender_name}}.",
+		PriorityHigh, true, "transaction_id"),
+	"KYC_STATUS_CHANGED": newEventTemplate(
+		`{{if eq .status "verified"}}Identity Verified{{else}}Identity Not Verified{{end}}`,
+		`{{if eq .status "verified"}}Your identity has been verified and your account is fully active.`+
+			`{{else}}We could not verify your identity{{with .reason}}: {{.}}{{end}}. Please check your documents and submit them again.{{end}}`,
+		PriorityHigh, true, "status"),
+	"LOGIN_NEW_DEVICE": newEventTemplate("New Login Detected",
+		"New login from {{.device_name}} near {{.location}}. If this wasn't you, freeze your account immediately.",
+		PriorityUrgent, true),
+	"RISK_ALERT": newEventTemplate("Security Alert",
+		"Your transaction was flagged: {{.reason}}. Please contact support.",
+		PriorityUrgent, true),
+	"CREDIT_FACILITY_OPENED": newEventTemplate("Overdraft Available",
+		"Your {{.currency}} wallet now has an overdraft of up to {{.limit}} {{.currency}} at {{.annual_rate}}% a year.",
+		PriorityNormal, true),
+	"CREDIT_PAST_DUE": newEventTemplate("Overdraft Past Due",
+		"Your overdraft is past due: you owe {{.overdrawn}} {{.currency}} plus {{.accrued_interest}} {{.currency}} interest. Please top up your wallet.",
+		PriorityHigh, true),
+	"CREDIT_DELINQUENT": newEventTemplate("Overdraft Suspended",
+		"Your overdraft has been suspended as it is overdue: you owe {{.overdrawn}} {{.currency}} plus {{.accrued_interest}} {{.currency}} interest. Please top up your wallet.",
+		PriorityUrgent, true),
+	"FUNDS_SWEPT": newEventTemplate("Money Set Aside",
+		"{{.amount}} {{.currency}} was moved to your {{.pocket}} pocket, which now holds {{.balance}} {{.currency}}.",
+		PriorityLow, false),
+	"STANDING_INSTRUCTION_FAILED": newEventTemplate("Standing Instruction Not Carried Out",
+		"We could not carry out your standing instruction on money arriving in your {{.currency}} wallet: {{.reason}}.",
+		PriorityHigh, true),
+	"PAYMENT_BATCH_COMPLETED": newEventTemplate("Payment Batch Processed",
+		"Your payment batch {{.name}} has been processed: {{.succeeded}} payments made, {{.failed}} not made. Download the report for details.",
+		PriorityNormal, true, "batch_id"),
+	"INVOICE_RECEIVED": newEventTemplate("Invoice Received",
+		"{{.merchant}} has sent you an invoice for {{.amount}} {{.currency}}. Pay it at {{.payment_link}}.",
+		PriorityNormal, true, "invoice_id"),
+	"INVOICE_PAID": newEventTemplate("Invoice Paid",
+		"Your invoice {{if .reference}}{{.reference}} {{end}}for {{.amount}} {{.currency}} has been paid.",
+		PriorityNormal, true, "invoice_id"),
+	// Complaint messages come in the complainant's language
+	"COMPLAINT_RECEIVED": newEventTemplate("Complaint {{.reference}}",
+		"{{.message}}", PriorityNormal, true, "complaint_id"),
+	"COMPLAINT_ACKNOWLEDGED": newEventTemplate("Complaint {{.reference}}",
+		"{{.message}}", PriorityNormal, true, "complaint_id"),
+	"COMPLAINT_RESOLVED": newEventTemplate("Complaint {{.reference}}",
+		"{{.message}}", PriorityHigh, true, "complaint_id"),
+}
+
+// securityEvents warn users their account may be at risk. They always go
+// out on every channel, whatever the user's preferences.
+var securityEvents = map[string]bool{
+	"LOGIN_NEW_DEVICE": true,
+	"RISK_ALERT":       true,
+	"SECURITY_ALERT":   true,
+}
+
+// render fills in the event's template. Events without one get a generic
+// notification.
+func render(eventType string, data map[string]interface{}) (subject, body string, priority Priority, err error) {
+	t, ok := eventTemplates[eventType]
+	if !ok {
+		return "Notification", fmt.Sprintf("Event: %s", eventType), PriorityNormal, nil
+	}
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, data); err != nil {
+		return "", "", 0, fmt.Errorf("render %s subject: %w", eventType, err)
+	}
+	subject = buf.String()
+	buf.Reset()
+	if err := t.body.Execute(&buf, data); err != nil {
+		return "", "", 0, fmt.Errorf("render %s body: %w", eventType, err)
+	}
+	return subject, buf.String(), t.priority, nil
+}
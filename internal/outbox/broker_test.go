@@ -0,0 +1,155 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNATS accepts one client, answers the handshake and records what it
+// publishes. Publishes to the subject "denied" are refused.
+func fakeNATS(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	published := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var pending string
+		fmt.Fprint(conn, "INFO {\"headers\":true}\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				if !strings.Contains(line, `"user":"svc"`) {
+					fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PONG":
+				published <- pending
+			case "HPUB":
+				total, _ := strconv.Atoi(fields[3])
+				hdrLen, _ := strconv.Atoi(fields[2])
+				buf := make([]byte, total+2)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					return
+				}
+				if fields[1] == "denied" {
+					fmt.Fprint(conn, "-ERR 'Permissions Violation for Publish to \"denied\"'\r\n")
+					return
+				}
+				// The server pings too; the message counts once the client
+				// has answered.
+				fmt.Fprint(conn, "PING\r\n")
+				pending = fields[1] + " " + string(buf[:hdrLen]) + string(buf[hdrLen:total])
+			}
+		}
+	}()
+	return ln.Addr().String(), published
+}
+
+func TestNATSBroker_Publish(t *testing.T) {
+	addr, published := fakeNATS(t)
+	b, err := NewNATSBroker("nats://svc:secret@" + addr)
+	require.NoError(t, err)
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, b.Publish(ctx, Message{Subject: "kyd.payment.completed", ID: "evt-1", Body: []byte(`{"id":"evt-1"}`)}))
+
+	select {
+	case got := <-published:
+		assert.Equal(t, "kyd.payment.completed NATS/1.0\r\nNats-Msg-Id: evt-1\r\n\r\n{\"id\":\"evt-1\"}", got)
+	case <-time.After(time.Second):
+		t.Fatal("nothing published")
+	}
+
+	err = b.Publish(ctx, Message{Subject: "denied", ID: "evt-2", Body: []byte(`{}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Permissions Violation")
+	assert.Nil(t, b.conn, "a failed connection is dropped")
+}
+
+func TestNATSBroker_AuthFailure(t *testing.T) {
+	addr, _ := fakeNATS(t)
+	b, err := NewNATSBroker("nats://" + addr)
+	require.NoError(t, err)
+	err = b.Publish(context.Background(), Message{Subject: "kyd.kyc.approved", ID: "evt-1", Body: []byte(`{}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Authorization Violation")
+}
+
+func TestNewNATSBroker_URL(t *testing.T) {
+	b, err := NewNATSBroker("nats://tok@nats.internal")
+	require.NoError(t, err)
+	assert.Equal(t, "nats.internal:4222", b.address)
+	assert.Equal(t, "tok", b.connect.AuthToken)
+	assert.Nil(t, b.tls)
+
+	b, err = NewNATSBroker("tls://nats.internal:4443")
+	require.NoError(t, err)
+	assert.NotNil(t, b.tls)
+
+	_, err = NewNATSBroker("http://nats.internal")
+	assert.Error(t, err)
+}
+
+func TestKafkaRESTBroker_Publish(t *testing.T) {
+	var gotPath, gotType string
+	var got map[string][]kafkaRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType = r.URL.Path, r.Header.Get("Content-Type")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if strings.HasSuffix(r.URL.Path, "/full") {
+			fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"Leader not available"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":12,"error_code":null,"error":null}]}`)
+	}))
+	defer srv.Close()
+
+	b, err := NewKafkaRESTBroker(srv.URL + "/")
+	require.NoError(t, err)
+	require.NoError(t, b.Publish(context.Background(), Message{Subject: "kyd.settlement.confirmed", Key: "agg-1", Body: []byte(`{"id":"evt-1"}`)}))
+	assert.Equal(t, "/topics/kyd.settlement.confirmed", gotPath)
+	assert.Equal(t, "application/vnd.kafka.json.v2+json", gotType)
+	require.Len(t, got["records"], 1)
+	assert.Equal(t, "agg-1", got["records"][0].Key)
+	assert.JSONEq(t, `{"id":"evt-1"}`, string(got["records"][0].Value))
+
+	err = b.Publish(context.Background(), Message{Subject: "full", Key: "agg-1", Body: []byte(`{}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Leader not available")
+}
+
+func TestNewBroker(t *testing.T) {
+	_, err := NewBroker("kafka", "http://proxy:8082")
+	assert.NoError(t, err)
+	_, err = NewBroker("rabbitmq", "amqp://localhost")
+	assert.Error(t, err)
+}
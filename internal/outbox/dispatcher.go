@@ -0,0 +1,259 @@
+// Package outbox publishes the domain events other services subscribe to.
+// Repositories write each event to the outbox table in the same database
+// transaction as the change it describes; the Dispatcher then publishes
+// pending events to the message broker, so an event goes out if and only
+// if its change committed. Delivery is at least once: subscribers should
+// use the event ID to ignore repeats.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Message is one event as handed to the broker.
+type Message struct {
+	// Subject is the NATS subject or Kafka topic.
+	Subject string
+	// Key orders messages about the same aggregate, where the broker
+	// supports it.
+	Key string
+	// ID lets brokers that deduplicate drop a repeat.
+	ID   string
+	Body []byte
+}
+
+// Broker publishes messages. Publish returns once the broker has accepted
+// the message.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// Store keeps the outbox. Satisfied by postgres.OutboxRepository.
+type Store interface {
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error
+	MarkFailed(ctx context.Context, id uuid.UUID, lastError string, next time.Time) error
+	PruneOutbox(ctx context.Context, before time.Time) (int, error)
+}
+
+// Envelope is the body of every message.
+type Envelope struct {
+	ID            uuid.UUID              `json:"id"`
+	Type          domain.OutboxEventType `json:"type"`
+	AggregateType string                 `json:"aggregate_type"`
+	AggregateID   uuid.UUID              `json:"aggregate_id"`
+	OccurredAt    time.Time              `json:"occurred_at"`
+	Data          json.RawMessage        `json:"data"`
+}
+
+// NewBroker returns the broker configured by name: "nats" or "kafka".
+func NewBroker(name, brokerURL string) (Broker, error) {
+	switch strings.ToLower(name) {
+	case "nats":
+		return NewNATSBroker(brokerURL)
+	case "kafka":
+		return NewKafkaRESTBroker(brokerURL)
+	}
+	return nil, fmt.Errorf("unknown outbox broker %q (want nats or kafka)", name)
+}
+
+// Dispatcher publishes pending outbox events.
+type Dispatcher struct {
+	store  Store
+	broker Broker
+	cfg    config.OutboxConfig
+	logger logger.Logger
+	pulse  heartbeat.Pulse
+	now    func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewDispatcher(store Store, broker Broker, cfg config.OutboxConfig, log logger.Logger) *Dispatcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = 10 * time.Second
+	}
+	return &Dispatcher{
+		store:  store,
+		broker: broker,
+		cfg:    cfg,
+		logger: log,
+		pulse:  heartbeat.Nop,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+}
+
+// WithHeartbeat beats p after each pass.
+func (d *Dispatcher) WithHeartbeat(p heartbeat.Pulse) *Dispatcher {
+	d.pulse = p
+	return d
+}
+
+// Start publishes due events every poll interval until Stop.
+func (d *Dispatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(d.cfg.PollInterval)
+		defer ticker.Stop()
+		lastPrune := time.Time{}
+		for {
+			ctx := context.Background()
+			d.Dispatch(ctx)
+			if d.cfg.Retention > 0 && d.now().Sub(lastPrune) >= time.Hour {
+				lastPrune = d.now()
+				d.prune(ctx)
+			}
+			d.pulse.Beat()
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	d.logger.Info("Outbox dispatcher started", map[string]interface{}{"interval": d.cfg.PollInterval.String()})
+}
+
+// Stop ends the dispatcher and closes its broker connection. It does not
+// wait for the batch in flight: an event cut short is published again,
+// which subscribers already have to tolerate.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		if err := d.broker.Close(); err != nil {
+			d.logger.Warn("Failed to close outbox broker", map[string]interface{}{"error": err.Error()})
+		}
+	})
+}
+
+// Dispatch publishes the events that are due, one batch at a time, and
+// returns how many were published.
+func (d *Dispatcher) Dispatch(ctx context.Context) int {
+	// A claimed event is left to this dispatcher for as long as a whole
+	// batch could take to publish.
+	lease := d.cfg.PublishTimeout * time.Duration(d.cfg.BatchSize+1)
+	published := 0
+	for {
+		batch, err := d.store.ClaimDue(ctx, d.now().UTC(), lease, d.cfg.BatchSize)
+		if err != nil {
+			d.logger.Error("Failed to claim outbox events", map[string]interface{}{"error": err.Error()})
+			return published
+		}
+		for i := range batch {
+			if d.publish(ctx, &batch[i]) {
+				published++
+			}
+		}
+		if len(batch) < d.cfg.BatchSize {
+			return published
+		}
+		select {
+		case <-d.stop:
+			return published
+		default:
+		}
+	}
+}
+
+// publish makes one attempt at e and records the outcome.
+func (d *Dispatcher) publish(ctx context.Context, e *domain.OutboxEvent) bool {
+	msg, err := d.message(e)
+	if err == nil {
+		pctx, cancel := context.WithTimeout(ctx, d.cfg.PublishTimeout)
+		err = d.broker.Publish(pctx, msg)
+		cancel()
+	}
+	if err == nil {
+		if err := d.store.MarkPublished(ctx, e.ID, d.now().UTC()); err != nil {
+			d.logger.Error("Failed to mark outbox event published", map[string]interface{}{"event_id": e.ID, "error": err.Error()})
+		}
+		return true
+	}
+
+	attempts := e.Attempts + 1
+	d.logger.Warn("Failed to publish outbox event", map[string]interface{}{
+		"event_id": e.ID,
+		"event":    e.Type,
+		"attempts": attempts,
+		"error":    err.Error(),
+	})
+	next := d.now().UTC().Add(d.backoff(attempts))
+	if err := d.store.MarkFailed(ctx, e.ID, err.Error(), next); err != nil {
+		d.logger.Error("Failed to record outbox attempt", map[string]interface{}{"event_id": e.ID, "error": err.Error()})
+	}
+	return false
+}
+
+// message wraps e in its envelope, addressed to prefix.type.
+func (d *Dispatcher) message(e *domain.OutboxEvent) (Message, error) {
+	body, err := json.Marshal(Envelope{
+		ID:            e.ID,
+		Type:          e.Type,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		OccurredAt:    e.CreatedAt.UTC(),
+		Data:          json.RawMessage(e.Payload),
+	})
+	if err != nil {
+		return Message{}, fmt.Errorf("encode event: %w", err)
+	}
+	return Message{
+		Subject: Subject(d.cfg.SubjectPrefix, e.Type),
+		Key:     e.AggregateID.String(),
+		ID:      e.ID.String(),
+		Body:    body,
+	}, nil
+}
+
+// Subject names the subject or topic events of type t are published to.
+func Subject(prefix string, t domain.OutboxEventType) string {
+	prefix = strings.TrimSuffix(prefix, ".")
+	if prefix == "" {
+		return string(t)
+	}
+	return prefix + "." + string(t)
+}
+
+// backoff doubles from the poll interval with each failed attempt, up to
+// the configured maximum.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.PollInterval
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if d.cfg.MaxBackoff > 0 && wait >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return wait
+}
+
+func (d *Dispatcher) prune(ctx context.Context) {
+	n, err := d.store.PruneOutbox(ctx, d.now().Add(-d.cfg.Retention))
+	if err != nil {
+		d.logger.Error("Failed to prune outbox events", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	if n > 0 {
+		d.logger.Info("Pruned published outbox events", map[string]interface{}{"count": n})
+	}
+}
@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore keeps the outbox the way the postgres repository does.
+type memStore struct {
+	events []*domain.OutboxEvent
+}
+
+func (s *memStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.OutboxEvent, error) {
+	var out []domain.OutboxEvent
+	for _, e := range s.events {
+		if len(out) == limit {
+			break
+		}
+		if e.PublishedAt == nil && !e.NextAttemptAt.After(now) {
+			e.NextAttemptAt = now.Add(lease)
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) find(id uuid.UUID) *domain.OutboxEvent {
+	for _, e := range s.events {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *memStore) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	e := s.find(id)
+	e.PublishedAt, e.LastError = &at, ""
+	e.Attempts++
+	return nil
+}
+
+func (s *memStore) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, next time.Time) error {
+	e := s.find(id)
+	e.Attempts++
+	e.LastError, e.NextAttemptAt = lastError, next
+	return nil
+}
+
+func (s *memStore) PruneOutbox(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+type fakeBroker struct {
+	sent []Message
+	err  error
+}
+
+func (b *fakeBroker) Publish(ctx context.Context, msg Message) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.sent = append(b.sent, msg)
+	return nil
+}
+
+func (b *fakeBroker) Close() error { return nil }
+
+func testConfig() config.OutboxConfig {
+	return config.OutboxConfig{
+		Enabled:       true,
+		SubjectPrefix: "kyd",
+		PollInterval:  time.Second,
+		BatchSize:     2,
+		MaxBackoff:    10 * time.Second,
+	}
+}
+
+func TestDispatcher_PublishesEnvelopes(t *testing.T) {
+	tx := &domain.Transaction{ID: uuid.New(), Reference: "KYD-1", Currency: "MWK"}
+	paid, err := domain.PaymentCompleted(tx)
+	require.NoError(t, err)
+	approved, err := domain.KYCApproved(uuid.New(), time.Now())
+	require.NoError(t, err)
+	confirmed, err := domain.SettlementConfirmed(&domain.Settlement{ID: uuid.New(), BatchReference: "B-1"})
+	require.NoError(t, err)
+	store := &memStore{events: []*domain.OutboxEvent{paid, approved, confirmed}}
+	broker := &fakeBroker{}
+
+	d := NewDispatcher(store, broker, testConfig(), logger.NewNop())
+	assert.Equal(t, 3, d.Dispatch(context.Background()), "batches continue until one comes back short")
+	require.Len(t, broker.sent, 3)
+
+	msg := broker.sent[0]
+	assert.Equal(t, "kyd.payment.completed", msg.Subject)
+	assert.Equal(t, tx.ID.String(), msg.Key)
+	assert.Equal(t, paid.ID.String(), msg.ID)
+	var env struct {
+		Envelope
+		Data domain.PaymentCompletedEvent `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(msg.Body, &env))
+	assert.Equal(t, paid.ID, env.ID)
+	assert.Equal(t, domain.OutboxPaymentCompleted, env.Type)
+	assert.Equal(t, "transaction", env.AggregateType)
+	assert.Equal(t, "KYD-1", env.Data.Reference)
+
+	assert.Equal(t, "kyd.kyc.approved", broker.sent[1].Subject)
+	assert.Equal(t, "kyd.settlement.confirmed", broker.sent[2].Subject)
+	for _, e := range store.events {
+		assert.NotNil(t, e.PublishedAt)
+	}
+	assert.Zero(t, d.Dispatch(context.Background()), "published events are not sent again")
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	e, err := domain.KYCApproved(uuid.New(), time.Now())
+	require.NoError(t, err)
+	store := &memStore{events: []*domain.OutboxEvent{e}}
+	broker := &fakeBroker{err: errors.New("nats: connection refused")}
+	d := NewDispatcher(store, broker, testConfig(), logger.NewNop())
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	assert.Zero(t, d.Dispatch(context.Background()))
+	assert.Equal(t, 1, e.Attempts)
+	assert.Equal(t, "nats: connection refused", e.LastError)
+	assert.Equal(t, now.UTC().Add(time.Second), e.NextAttemptAt)
+
+	assert.Zero(t, d.Dispatch(context.Background()), "not due again yet")
+
+	now = now.Add(time.Second)
+	d.Dispatch(context.Background())
+	assert.Equal(t, now.UTC().Add(2*time.Second), e.NextAttemptAt)
+
+	assert.Equal(t, 10*time.Second, d.backoff(20), "capped at the maximum")
+
+	broker.err = nil
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, 1, d.Dispatch(context.Background()))
+	assert.NotNil(t, e.PublishedAt)
+	assert.Empty(t, e.LastError)
+}
+
+func TestSubject(t *testing.T) {
+	assert.Equal(t, "kyd.kyc.approved", Subject("kyd.", domain.OutboxKYCApproved))
+	assert.Equal(t, "kyc.approved", Subject("", domain.OutboxKYCApproved))
+}
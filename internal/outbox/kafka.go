@@ -0,0 +1,91 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// KafkaRESTBroker publishes to Kafka through a Kafka REST Proxy (v2 API).
+// Messages are keyed by aggregate, so the events of one payment, user or
+// settlement land on one partition in order.
+type KafkaRESTBroker struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewKafkaRESTBroker(baseURL string) (*KafkaRESTBroker, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid kafka rest proxy url %q", baseURL)
+	}
+	return &KafkaRESTBroker{baseURL: strings.TrimRight(baseURL, "/"), client: &http.Client{}}, nil
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition *int    `json:"partition"`
+		Offset    *int64  `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish produces msg to the topic msg.Subject and returns once the proxy
+// reports it written.
+func (b *KafkaRESTBroker) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string][]kafkaRecord{
+		"records": {{Key: msg.Key, Value: msg.Body}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/topics/"+url.PathEscape(msg.Subject), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka produce: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("kafka produce: %s: %s", resp.Status, strings.TrimSpace(string(raw)))
+	}
+	var out kafkaProduceResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return fmt.Errorf("kafka produce: invalid response: %w", err)
+	}
+	for _, o := range out.Offsets {
+		if o.ErrorCode != nil || o.Error != nil {
+			reason := ""
+			if o.Error != nil {
+				reason = *o.Error
+			}
+			return fmt.Errorf("kafka produce: %s", reason)
+		}
+	}
+	if len(out.Offsets) == 0 {
+		return fmt.Errorf("kafka produce: no offset returned")
+	}
+	return nil
+}
+
+func (b *KafkaRESTBroker) Close() error {
+	b.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,195 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSBroker publishes to a NATS server over its client protocol. Each
+// message is followed by a PING, so Publish returns only once the server
+// has processed it; the message carries a Nats-Msg-Id header, which
+// JetStream streams use to drop repeats.
+type NATSBroker struct {
+	network, address string
+	tls              *tls.Config
+	connect          natsConnect
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// natsConnect is the CONNECT handshake sent on every new connection.
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Headers   bool   `json:"headers"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// NewNATSBroker returns a broker for the server at rawURL:
+// nats://[user:pass@|token@]host[:port], or tls:// to connect over TLS. It
+// connects on first use.
+func NewNATSBroker(rawURL string) (*NATSBroker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats url: %w", err)
+	}
+	b := &NATSBroker{
+		network: "tcp",
+		connect: natsConnect{Headers: true, Name: "kyd-outbox", Lang: "go", Version: "1.0.0"},
+	}
+	switch u.Scheme {
+	case "nats":
+	case "tls":
+		b.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("invalid nats url: scheme must be nats or tls, got %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("invalid nats url: missing host")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "4222"
+	}
+	b.address = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			b.connect.User, b.connect.Pass = u.User.Username(), pass
+		} else {
+			b.connect.AuthToken = u.User.Username()
+		}
+	}
+	return b, nil
+}
+
+// Publish sends msg and waits for the server to acknowledge it.
+func (b *NATSBroker) Publish(ctx context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil {
+		if err := b.dial(ctx); err != nil {
+			return err
+		}
+	}
+	err := b.publish(ctx, msg)
+	if err != nil {
+		// The connection is in an unknown state; start afresh next time.
+		b.closeConn()
+	}
+	return err
+}
+
+func (b *NATSBroker) publish(ctx context.Context, msg Message) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		b.conn.SetDeadline(deadline)
+	} else {
+		b.conn.SetDeadline(time.Time{})
+	}
+	headers := "NATS/1.0\r\nNats-Msg-Id: " + msg.ID + "\r\n\r\n"
+	frame := fmt.Sprintf("HPUB %s %d %d\r\n%s%s\r\nPING\r\n",
+		msg.Subject, len(headers), len(headers)+len(msg.Body), headers, msg.Body)
+	if _, err := b.conn.Write([]byte(frame)); err != nil {
+		return fmt.Errorf("nats publish: %w", err)
+	}
+	return b.awaitPong()
+}
+
+// dial connects, reads the server's INFO and completes the handshake.
+func (b *NATSBroker) dial(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, b.network, b.address)
+	if err != nil {
+		return fmt.Errorf("nats connect: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	b.conn, b.r = conn, bufio.NewReader(conn)
+
+	line, err := b.r.ReadString('\n')
+	if err != nil {
+		b.closeConn()
+		return fmt.Errorf("nats connect: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		b.closeConn()
+		return fmt.Errorf("nats connect: unexpected greeting %q", strings.TrimSpace(line))
+	}
+	if b.tls != nil {
+		tc := tls.Client(conn, b.tls)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			b.closeConn()
+			return fmt.Errorf("nats tls: %w", err)
+		}
+		b.conn, b.r = tc, bufio.NewReader(tc)
+	}
+
+	opts, err := json.Marshal(b.connect)
+	if err != nil {
+		b.closeConn()
+		return err
+	}
+	if _, err := fmt.Fprintf(b.conn, "CONNECT %s\r\nPING\r\n", opts); err != nil {
+		b.closeConn()
+		return fmt.Errorf("nats connect: %w", err)
+	}
+	if err := b.awaitPong(); err != nil {
+		b.closeConn()
+		return err
+	}
+	return nil
+}
+
+// awaitPong reads until the server answers the last PING, answering its
+// own PINGs and failing on -ERR.
+func (b *NATSBroker) awaitPong() error {
+	for {
+		line, err := b.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("nats: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := b.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+		// +OK and INFO updates need no answer.
+	}
+}
+
+func (b *NATSBroker) closeConn() {
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn, b.r = nil, nil
+	}
+}
+
+func (b *NATSBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closeConn()
+	return nil
+}
@@ -0,0 +1,149 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// invoiceNamespace derives the one transaction that pays an invoice from
+// the invoice ID, so every collection attempt reuses it.
+var invoiceNamespace = uuid.MustParse("6f1c4c1e-2b7d-4f57-9a43-5b0e3f4d8c21")
+
+// UsageMeter records billable usage.
+type UsageMeter interface {
+	RecordUsage(ctx context.Context, userID uuid.UUID, metric domain.UsageMetric, quantity decimal.Decimal, currency domain.Currency) error
+}
+
+// WithUsageMeter meters the payout volume of senders whose plan includes API
+// access.
+func (s *Service) WithUsageMeter(meter UsageMeter) *Service {
+	s.usage = meter
+	return s
+}
+
+// meterPayout records a completed payment towards the sender's payout
+// volume. ent is loaded when the caller does not have it. Metering never
+// fails the payment.
+func (s *Service) meterPayout(ctx context.Context, tx *domain.Transaction, ent *domain.Entitlements) {
+	if s.usage == nil {
+		return
+	}
+	if ent == nil && s.entitlements != nil {
+		var err error
+		if ent, err = s.entitlements.Entitlements(ctx, tx.SenderID); err != nil {
+			s.logger.Error("Failed to load entitlements for usage metering", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+			return
+		}
+	}
+	if ent == nil || !ent.APIAccess {
+		return
+	}
+	if err := s.usage.RecordUsage(ctx, tx.SenderID, domain.UsagePayoutVolume, tx.Amount, tx.Currency); err != nil {
+		s.logger.Error("Failed to meter payout volume", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+	}
+}
+
+// CollectInvoice debits an invoice from the account's wallet in the
+// invoice currency to the fee collection account. Each invoice is paid by
+// one transaction, so retrying after a failure, or after a success whose
+// outcome was lost, never debits twice.
+func (s *Service) CollectInvoice(ctx context.Context, inv *domain.Invoice) (*domain.Transaction, error) {
+	if s.feeCollectorUserID == nil {
+		return nil, errors.New("billing collection account is not configured")
+	}
+	wallet, err := s.walletRepo.FindByUserAndCurrency(ctx, inv.UserID, inv.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, pkgerrors.ErrWalletNotFound
+	}
+	collector, err := s.walletRepo.FindByUserAndCurrency(ctx, *s.feeCollectorUserID, inv.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if collector == nil {
+		return nil, fmt.Errorf("no %s billing collection wallet", inv.Currency)
+	}
+
+	txID := uuid.NewSHA1(invoiceNamespace, []byte(inv.ID.String()))
+	tx, err := s.repo.FindByID(ctx, txID)
+	if err != nil && !errors.Is(err, pkgerrors.ErrTransactionNotFound) {
+		return nil, err
+	}
+	now := time.Now()
+	if tx == nil {
+		tx = &domain.Transaction{
+			ID:                txID,
+			Reference:         s.generateReference(),
+			SenderID:          inv.UserID,
+			ReceiverID:        *s.feeCollectorUserID,
+			SenderWalletID:    &wallet.ID,
+			ReceiverWalletID:  &collector.ID,
+			Amount:            inv.Total,
+			Currency:          inv.Currency,
+			ConvertedAmount:   inv.Total,
+			ConvertedCurrency: inv.Currency,
+			ExchangeRate:      decimal.NewFromInt(1),
+			NetAmount:         inv.Total,
+			Status:            domain.TransactionStatusPending,
+			TransactionType:   domain.TransactionTypePayment,
+			Channel:           "billing",
+			Category:          "billing",
+			Description:       "Invoice " + inv.Number,
+			InitiatedAt:       now,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+			Metadata: domain.Metadata{
+				"type":       "INVOICE",
+				"invoice_id": inv.ID.String(),
+			},
+		}
+		if err := s.repo.Create(ctx, tx); err != nil {
+			return nil, err
+		}
+	} else if tx.Status == domain.TransactionStatusCompleted {
+		return tx, nil
+	}
+
+	err = s.ledgerService.PostTransaction(ctx, &ledger.LedgerPosting{
+		TransactionID:     tx.ID,
+		DebitWalletID:     wallet.ID,
+		CreditWalletID:    collector.ID,
+		DebitAmount:       tx.Amount,
+		CreditAmount:      tx.Amount,
+		Currency:          tx.Currency,
+		ConvertedCurrency: tx.Currency,
+		ExchangeRate:      tx.ExchangeRate,
+		FeeAmount:         decimal.Zero,
+		Reference:         tx.Reference,
+		Description:       tx.Description,
+		IdempotencyKey:    "invoice:" + inv.ID.String(),
+	})
+	now = time.Now()
+	tx.UpdatedAt = now
+	if err != nil {
+		tx.Status = domain.TransactionStatusFailed
+		tx.StatusReason = err.Error()
+		if updateErr := s.repo.Update(ctx, tx); updateErr != nil {
+			s.logger.Error("Failed to update invoice transaction", map[string]interface{}{"transaction_id": tx.ID, "error": updateErr.Error()})
+		}
+		return nil, err
+	}
+	tx.Status = domain.TransactionStatusCompleted
+	tx.StatusReason = ""
+	tx.CompletedAt = &now
+	if err := s.repo.Update(ctx, tx); err != nil {
+		s.logger.Error("Failed to update invoice transaction", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+	}
+	return tx, nil
+}
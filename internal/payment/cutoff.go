@@ -0,0 +1,348 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	"kyd/pkg/clock"
+	"kyd/pkg/logger"
+
+	"github.com/shopspring/decimal"
+)
+
+// Metadata keys of a payment queued for its corridor's next window.
+const (
+	queuedUntilKey    = "queued_until"
+	queuedCorridorKey = "queued_corridor"
+)
+
+// CutOffWindow is the part of each business day, in the business zone,
+// during which a corridor's destination rail accepts payments. Open and
+// Close are offsets from midnight.
+type CutOffWindow struct {
+	Corridor string // e.g. "MWK-ZAR", or "*" for every cross-border corridor
+	Open     time.Duration
+	Close    time.Duration
+}
+
+// ParseCutOffWindows reads windows written as "MWK-ZAR=08:00-15:30".
+func ParseCutOffWindows(specs []string) ([]CutOffWindow, error) {
+	windows := make([]CutOffWindow, 0, len(specs))
+	for _, spec := range specs {
+		corridor, hours, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(spec)), "=")
+		open, close, ok2 := strings.Cut(hours, "-")
+		if !ok || !ok2 || corridor == "" {
+			return nil, fmt.Errorf("invalid cut-off window %q, want CORRIDOR=HH:MM-HH:MM", spec)
+		}
+		w := CutOffWindow{Corridor: corridor}
+		var err error
+		if w.Open, err = parseTimeOfDay(open); err != nil {
+			return nil, fmt.Errorf("invalid cut-off window %q: %w", spec, err)
+		}
+		if w.Close, err = parseTimeOfDay(close); err != nil {
+			return nil, fmt.Errorf("invalid cut-off window %q: %w", spec, err)
+		}
+		if w.Close <= w.Open {
+			return nil, fmt.Errorf("invalid cut-off window %q: closes before it opens", spec)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// NextOpen reports whether the window is open at t and, if not, when it
+// next opens. Windows only open on business days.
+func (w CutOffWindow) NextOpen(t time.Time) (time.Time, bool) {
+	local := t.In(clock.Business())
+	for d := 0; d < 8; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, local.Location())
+		if clock.IsWeekend(day) {
+			continue
+		}
+		open := atOffset(day, w.Open)
+		if t.Before(open) {
+			return open.UTC(), false
+		}
+		if t.Before(atOffset(day, w.Close)) {
+			return time.Time{}, true
+		}
+	}
+	return time.Time{}, true // unreachable: a week always has a business day
+}
+
+// atOffset is the wall-clock time off after midnight on day, so that a
+// window keeps its hours across daylight saving changes.
+func atOffset(day time.Time, off time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(off/time.Hour), int(off%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// QueueRepository finds queued payments and moves them out of the queue.
+type QueueRepository interface {
+	// FindDueQueued returns queued payments whose window opened by now.
+	FindDueQueued(ctx context.Context, now time.Time, limit int) ([]*domain.Transaction, error)
+	// TransitionStatus moves a transaction to tx.Status, reporting false
+	// when it had already left from, so that a release and a cancel cannot
+	// both go through.
+	TransitionStatus(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error)
+}
+
+// WithCutOffs queues cross-border payments initiated outside their
+// corridor's window until it next opens.
+func (s *Service) WithCutOffs(windows []CutOffWindow, repo QueueRepository) *Service {
+	s.cutOffs = windows
+	s.queue = repo
+	return s
+}
+
+// corridorWindow returns the window of the corridor from one currency to
+// another, if it has one.
+func (s *Service) corridorWindow(from, to domain.Currency) (CutOffWindow, bool) {
+	if s.queue == nil || from == to {
+		return CutOffWindow{}, false
+	}
+	corridor := string(from) + "-" + string(to)
+	var wildcard *CutOffWindow
+	for i, w := range s.cutOffs {
+		switch w.Corridor {
+		case corridor:
+			return w, true
+		case "*":
+			wildcard = &s.cutOffs[i]
+		}
+	}
+	if wildcard != nil {
+		return *wildcard, true
+	}
+	return CutOffWindow{}, false
+}
+
+// queuedUntil returns when a queued payment's window opens.
+func queuedUntil(tx *domain.Transaction) (time.Time, bool) {
+	v, ok := tx.Metadata[queuedUntilKey].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, err == nil
+}
+
+// queuePayment holds the full debit of a payment initiated after its
+// corridor's cut-off. The funds stay reserved in the sender's wallet until
+// the window opens or the sender cancels.
+func (s *Service) queuePayment(ctx context.Context, tx *domain.Transaction, totalDebit decimal.Decimal) error {
+	if err := s.reserveQueued(ctx, tx, totalDebit); err != nil {
+		tx.Status = domain.TransactionStatusFailed
+		tx.StatusReason = "We could not reserve the funds for your queued payment."
+		tx.UpdatedAt = time.Now()
+		if updateErr := s.repo.Update(ctx, tx); updateErr != nil {
+			s.logger.Error("Failed to update transaction status to failed", map[string]interface{}{"error": updateErr.Error(), "transaction_id": tx.ID})
+		}
+		s.recordEvent(ctx, tx.ID, domain.TransactionEventFailed, domain.Metadata{"reason": tx.StatusReason})
+		return fmt.Errorf("failed to reserve funds: %w", err)
+	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventQueued, domain.Metadata{
+		"corridor":     tx.Metadata[queuedCorridorKey],
+		"queued_until": tx.Metadata[queuedUntilKey],
+	})
+	s.logger.Info("Payment queued for next corridor window", map[string]interface{}{
+		"transaction_id": tx.ID,
+		"corridor":       tx.Metadata[queuedCorridorKey],
+		"queued_until":   tx.Metadata[queuedUntilKey],
+	})
+	return nil
+}
+
+// cancelQueued cancels a queued payment and hands its reserved funds back.
+func (s *Service) cancelQueued(ctx context.Context, tx *domain.Transaction) error {
+	if tx.SenderWalletID == nil {
+		return errors.New("sender wallet missing")
+	}
+	now := time.Now()
+	tx.Status = domain.TransactionStatusCancelled
+	tx.CompletedAt = &now
+	tx.UpdatedAt = now
+	if ok, err := s.queue.TransitionStatus(ctx, tx, domain.TransactionStatusQueued); err != nil {
+		return err
+	} else if !ok {
+		return errors.New("only pending or queued transactions can be cancelled")
+	}
+	if err := s.walletRepo.ReleaseReservedFunds(ctx, *tx.SenderWalletID, tx.Amount.Add(tx.FeeAmount)); err != nil {
+		s.requeue(ctx, tx, domain.TransactionStatusCancelled)
+		return fmt.Errorf("failed to release reserved funds: %w", err)
+	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventCancelled, domain.Metadata{"reason": "You cancelled this payment."})
+	return nil
+}
+
+// requeue puts a payment back in the queue after its funds failed to move.
+func (s *Service) requeue(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) {
+	tx.Status = domain.TransactionStatusQueued
+	tx.CompletedAt = nil
+	tx.UpdatedAt = time.Now()
+	if _, err := s.queue.TransitionStatus(ctx, tx, from); err != nil {
+		s.logger.Error("Failed to return payment to the queue", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+	}
+}
+
+// ReleaseQueuedPayments processes queued payments whose window has opened,
+// returning how many were released.
+func (s *Service) ReleaseQueuedPayments(ctx context.Context, now time.Time) (int, error) {
+	if s.queue == nil {
+		return 0, nil
+	}
+	txs, err := s.queue.FindDueQueued(ctx, now, 100)
+	if err != nil {
+		return 0, err
+	}
+	released := 0
+	for _, tx := range txs {
+		if err := s.releaseQueued(ctx, tx); err != nil {
+			s.logger.Error("Failed to release queued payment", map[string]interface{}{"transaction_id": tx.ID, "error": err.Error()})
+			continue
+		}
+		released++
+	}
+	return released, nil
+}
+
+// releaseQueued converts a queued payment at the rate when its window
+// opens, unless it was made under a quote whose rate it keeps, and posts it
+// as if it had just been initiated.
+func (s *Service) releaseQueued(ctx context.Context, tx *domain.Transaction) error {
+	if tx.SenderWalletID == nil || tx.ReceiverWalletID == nil {
+		return errors.New("queued payment wallet missing")
+	}
+	senderWallet, err := s.walletRepo.FindByID(ctx, *tx.SenderWalletID)
+	if err != nil {
+		return err
+	}
+	receiverWallet, err := s.walletRepo.FindByID(ctx, *tx.ReceiverWalletID)
+	if err != nil {
+		return err
+	}
+	if _, quoted := tx.Metadata[quoteKey]; !quoted && senderWallet.Currency != receiverWallet.Currency {
+		// A rate outage leaves the payment queued for the next run.
+		rate, err := s.forexService.GetRate(ctx, senderWallet.Currency, receiverWallet.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to get exchange rate: %w", err)
+		}
+		tx.ExchangeRate = rate.SellRate
+		tx.ConvertedAmount = tx.Amount.Mul(rate.SellRate)
+		tx.NetAmount = tx.ConvertedAmount
+		tx.Metadata = withFXMidRate(tx.Metadata, rate.Rate)
+	}
+
+	// Claim the payment before moving money, so that a concurrent cancel
+	// finds it no longer queued.
+	tx.Status = domain.TransactionStatusProcessing
+	tx.UpdatedAt = time.Now()
+	if ok, err := s.queue.TransitionStatus(ctx, tx, domain.TransactionStatusQueued); err != nil {
+		return err
+	} else if !ok {
+		return nil // cancelled or released elsewhere
+	}
+
+	totalDebit := tx.Amount.Add(tx.FeeAmount)
+	if err := s.walletRepo.ReleaseReservedFunds(ctx, senderWallet.ID, totalDebit); err != nil {
+		s.requeue(ctx, tx, domain.TransactionStatusProcessing)
+		return fmt.Errorf("failed to release reserved funds: %w", err)
+	}
+	if err := s.processPayment(ctx, tx, senderWallet, receiverWallet, totalDebit); err != nil {
+		tx.Status = domain.TransactionStatusFailed
+		tx.StatusReason = err.Error()
+		tx.UpdatedAt = time.Now()
+		s.recordEvent(ctx, tx.ID, domain.TransactionEventFailed, domain.Metadata{"reason": "We could not debit your wallet."})
+		if updateErr := s.repo.Update(ctx, tx); updateErr != nil {
+			s.logger.Error("Failed to update transaction status to failed", map[string]interface{}{"error": updateErr.Error(), "transaction_id": tx.ID})
+		}
+		return err
+	}
+
+	s.recordConversion(ctx, tx)
+	s.meterPayout(ctx, tx, nil)
+
+	tx.Status = domain.TransactionStatusPendingSettlement
+	now := time.Now()
+	tx.CompletedAt = &now
+	tx.UpdatedAt = now
+	if err := s.repo.Update(ctx, tx); err != nil {
+		return err
+	}
+
+	go func() {
+		_ = s.notifier.Notify(context.Background(), tx.SenderID, "PAYMENT_SENT", map[string]interface{}{
+			"transaction_id": tx.ID.String(),
+			"amount":         tx.Amount.String(),
+			"currency":       tx.Currency,
+			"receiver_name":  tx.ReceiverID.String(),
+		})
+		_ = s.notifier.Notify(context.Background(), tx.ReceiverID, "PAYMENT_RECEIVED", map[string]interface{}{
+			"transaction_id": tx.ID.String(),
+			"amount":         tx.ConvertedAmount.String(),
+			"currency":       tx.ConvertedCurrency,
+			"sender_name":    tx.SenderID.String(),
+		})
+	}()
+	return nil
+}
+
+// QueueReleaseWorker releases queued payments as their windows open.
+type QueueReleaseWorker struct {
+	service  *Service
+	interval time.Duration
+	logger   logger.Logger
+	pulse    heartbeat.Pulse
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewQueueReleaseWorker(service *Service, interval time.Duration, log logger.Logger) *QueueReleaseWorker {
+	return &QueueReleaseWorker{service: service, interval: interval, logger: log, pulse: heartbeat.Nop, stop: make(chan struct{})}
+}
+
+// WithHeartbeat beats p after every pass.
+func (w *QueueReleaseWorker) WithHeartbeat(p heartbeat.Pulse) *QueueReleaseWorker {
+	w.pulse = p
+	return w
+}
+
+func (w *QueueReleaseWorker) Start() {
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := w.service.ReleaseQueuedPayments(context.Background(), time.Now())
+				if err != nil {
+					w.logger.Error("Failed to release queued payments", map[string]interface{}{"error": err.Error()})
+				} else if n > 0 {
+					w.logger.Info("Released queued payments", map[string]interface{}{"count": n})
+				}
+				w.pulse.Beat()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	w.logger.Info("Queued payment worker started", map[string]interface{}{"interval": w.interval.String()})
+}
+
+func (w *QueueReleaseWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
@@ -0,0 +1,200 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func (m *memEscrows) FindDueQueued(ctx context.Context, now time.Time, limit int) ([]*domain.Transaction, error) {
+	var out []*domain.Transaction
+	for _, tx := range m.txs {
+		if eta, ok := queuedUntil(tx); ok && tx.Status == domain.TransactionStatusQueued && !eta.After(now) {
+			cp := *tx
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memEscrows) TransitionStatus(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error) {
+	return m.TransitionEscrow(ctx, tx, from)
+}
+
+func (w *escrowWallets) FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	if wl, ok := w.wallets[id]; ok {
+		return wl, nil
+	}
+	return nil, pkgerrors.ErrWalletNotFound
+}
+
+func TestParseCutOffWindows(t *testing.T) {
+	windows, err := ParseCutOffWindows([]string{"mwk-zar=08:00-15:30", " *=07:00-17:00 "})
+	require.NoError(t, err)
+	assert.Equal(t, []CutOffWindow{
+		{Corridor: "MWK-ZAR", Open: 8 * time.Hour, Close: 15*time.Hour + 30*time.Minute},
+		{Corridor: "*", Open: 7 * time.Hour, Close: 17 * time.Hour},
+	}, windows)
+
+	for _, bad := range []string{"MWK-ZAR", "MWK-ZAR=8-15", "MWK-ZAR=15:00-08:00", "=08:00-15:00"} {
+		_, err := ParseCutOffWindows([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCutOffWindow_NextOpen(t *testing.T) {
+	w := CutOffWindow{Corridor: "MWK-ZAR", Open: 8 * time.Hour, Close: 15*time.Hour + 30*time.Minute}
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 5, day, hour, min, 0, 0, time.UTC) }
+
+	_, open := w.NextOpen(at(15, 9, 0)) // Wednesday
+	assert.True(t, open)
+
+	next, open := w.NextOpen(at(15, 7, 59))
+	assert.False(t, open)
+	assert.Equal(t, at(15, 8, 0), next, "before the window opens")
+
+	next, _ = w.NextOpen(at(15, 15, 30))
+	assert.Equal(t, at(16, 8, 0), next, "after the cut-off")
+
+	next, _ = w.NextOpen(at(17, 16, 0))
+	assert.Equal(t, at(20, 8, 0), next, "Friday after the cut-off waits for Monday")
+
+	next, _ = w.NextOpen(at(18, 10, 0))
+	assert.Equal(t, at(20, 8, 0), next, "weekends are closed")
+}
+
+func TestCorridorWindow(t *testing.T) {
+	windows, err := ParseCutOffWindows([]string{"MWK-ZAR=08:00-15:30", "*=07:00-17:00"})
+	require.NoError(t, err)
+	s := NewService(nil, nil, nil, nil, nil, nil, nil, nil, logger.NewNop(), nil)
+
+	_, ok := s.corridorWindow(domain.MWK, domain.ZAR)
+	assert.False(t, ok, "no queue without a repository")
+
+	s.WithCutOffs(windows, &memEscrows{})
+	w, ok := s.corridorWindow(domain.MWK, domain.ZAR)
+	require.True(t, ok)
+	assert.Equal(t, "MWK-ZAR", w.Corridor)
+	w, ok = s.corridorWindow(domain.MWK, domain.CNY)
+	require.True(t, ok)
+	assert.Equal(t, "*", w.Corridor)
+	_, ok = s.corridorWindow(domain.MWK, domain.MWK)
+	assert.False(t, ok, "domestic payments never queue")
+}
+
+func newQueueService(t *testing.T) (*Service, *memEscrows, *escrowWallets, *MockLedgerService, func(eta time.Time) *domain.Transaction) {
+	sender := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.MWK}
+	receiver := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.ZAR}
+	wallets := &escrowWallets{
+		wallets:   map[uuid.UUID]*domain.Wallet{sender.ID: sender, receiver.ID: receiver},
+		available: map[uuid.UUID]decimal.Decimal{sender.ID: decimal.NewFromInt(1000)},
+		reserved:  map[uuid.UUID]decimal.Decimal{},
+	}
+	repo := &memEscrows{txs: map[uuid.UUID]*domain.Transaction{}}
+	forex := new(MockForexService)
+	forex.On("GetRate", mock.Anything, domain.MWK, domain.ZAR).Return(&domain.ExchangeRate{SellRate: decimal.NewFromFloat(0.011)}, nil)
+	ledger := new(MockLedgerService)
+	notifier := new(MockNotificationService)
+	notifier.On("Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s := NewService(repo, wallets, forex, ledger, nil, notifier, nil, nil, logger.NewNop(), nil).
+		WithCutOffs([]CutOffWindow{{Corridor: "*", Open: 8 * time.Hour, Close: 16 * time.Hour}}, repo)
+
+	// queue stands in for InitiatePayment after the cut-off.
+	queue := func(eta time.Time) *domain.Transaction {
+		tx := &domain.Transaction{
+			ID:                uuid.New(),
+			SenderID:          sender.UserID,
+			ReceiverID:        receiver.UserID,
+			SenderWalletID:    &sender.ID,
+			ReceiverWalletID:  &receiver.ID,
+			Amount:            decimal.NewFromInt(100),
+			Currency:          domain.MWK,
+			ExchangeRate:      decimal.NewFromFloat(0.01),
+			ConvertedAmount:   decimal.NewFromInt(1),
+			ConvertedCurrency: domain.ZAR,
+			FeeAmount:         decimal.NewFromFloat(1.5),
+			Status:            domain.TransactionStatusQueued,
+			Metadata:          domain.Metadata{queuedUntilKey: eta.Format(time.RFC3339), queuedCorridorKey: "MWK-ZAR"},
+		}
+		require.NoError(t, repo.Create(context.Background(), tx))
+		require.NoError(t, s.queuePayment(context.Background(), tx, tx.Amount.Add(tx.FeeAmount)))
+		return tx
+	}
+	return s, repo, wallets, ledger, queue
+}
+
+func TestQueuedPayment_Cancel(t *testing.T) {
+	ctx := context.Background()
+	s, repo, wallets, _, queue := newQueueService(t)
+	tx := queue(time.Now().Add(time.Hour))
+	sender := *tx.SenderWalletID
+	assert.True(t, wallets.reserved[sender].Equal(decimal.NewFromFloat(101.5)))
+
+	require.NoError(t, s.CancelTransaction(ctx, tx.ID, tx.SenderID))
+	assert.Equal(t, domain.TransactionStatusCancelled, repo.txs[tx.ID].Status)
+	assert.True(t, wallets.reserved[sender].IsZero())
+	assert.True(t, wallets.available[sender].Equal(decimal.NewFromInt(1000)))
+
+	assert.ErrorContains(t, s.CancelTransaction(ctx, tx.ID, tx.SenderID), "only pending or queued")
+}
+
+func TestReleaseQueuedPayments(t *testing.T) {
+	ctx := context.Background()
+	s, repo, wallets, ledger, queue := newQueueService(t)
+	due := queue(time.Now().Add(-time.Minute))
+	later := queue(time.Now().Add(time.Hour))
+	ledger.On("PostTransaction", ctx, mock.Anything).Return(nil)
+
+	n, err := s.ReleaseQueuedPayments(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	released := repo.txs[due.ID]
+	assert.Equal(t, domain.TransactionStatusPendingSettlement, released.Status)
+	assert.True(t, released.ExchangeRate.Equal(decimal.NewFromFloat(0.011)), "converted at the rate when the window opened")
+	assert.True(t, released.ConvertedAmount.Equal(decimal.NewFromFloat(1.1)))
+	assert.Equal(t, domain.TransactionStatusQueued, repo.txs[later.ID].Status)
+	assert.True(t, wallets.reserved[*due.SenderWalletID].Equal(decimal.NewFromFloat(101.5)), "only the due payment's funds were released")
+	ledger.AssertNumberOfCalls(t, "PostTransaction", 1)
+}
+
+func TestReleaseQueuedPayments_KeepsQuotedRate(t *testing.T) {
+	ctx := context.Background()
+	s, repo, _, ledger, queue := newQueueService(t)
+	tx := queue(time.Now().Add(-time.Minute))
+	repo.txs[tx.ID].Metadata[quoteKey] = uuid.NewString()
+	ledger.On("PostTransaction", ctx, mock.Anything).Return(nil)
+
+	_, err := s.ReleaseQueuedPayments(ctx, time.Now())
+	require.NoError(t, err)
+	released := repo.txs[tx.ID]
+	assert.Equal(t, domain.TransactionStatusPendingSettlement, released.Status)
+	assert.True(t, released.ExchangeRate.Equal(decimal.NewFromFloat(0.01)), "converted at the quoted rate")
+}
+
+func TestTimeline_QueuedPaymentShowsETA(t *testing.T) {
+	tx := &domain.Transaction{
+		ID:                uuid.New(),
+		Currency:          domain.MWK,
+		ConvertedCurrency: domain.ZAR,
+		Status:            domain.TransactionStatusQueued,
+		Metadata:          domain.Metadata{queuedUntilKey: "2024-05-20T08:00:00Z"},
+	}
+	tl := buildTimeline(tx, []*domain.TransactionEvent{
+		{Type: domain.TransactionEventInitiated},
+		{Type: domain.TransactionEventCompliancePassed},
+		{Type: domain.TransactionEventQueued},
+	})
+	assert.Contains(t, tl.NextStep, "20 May 2024 08:00 UTC")
+	assert.Contains(t, tl.NextStep, "cancel")
+}
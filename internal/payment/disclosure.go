@@ -0,0 +1,241 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// disclosureKey is the metadata key of the disclosure a payment was made
+// under.
+const disclosureKey = "disclosure_id"
+
+// rightsNotice is given with every disclosure.
+const rightsNotice = "You can cancel this payment while it is pending or queued for its corridor's next window. " +
+	"If the recipient does not receive the amount disclosed by the date disclosed, or you believe there is another error, " +
+	"open a dispute on the payment within 180 days of that date and we will investigate and correct it."
+
+var (
+	// ErrInvalidDisclosure is returned for a payment that cannot be
+	// disclosed, such as one without a receiver.
+	ErrInvalidDisclosure = errors.New("invalid disclosure request")
+	// ErrDisclosureNotFound is returned for a disclosure the customer was
+	// not issued, or a payment made without one.
+	ErrDisclosureNotFound = errors.New("payment disclosure not found")
+	// ErrDisclosureUnusable is returned when a payment cannot be made under
+	// its disclosure: it has expired or been used, is for another payment,
+	// or promised better terms than the payment now gets.
+	ErrDisclosureUnusable = errors.New("payment disclosure cannot be used")
+	// ErrDisclosuresDisabled is returned when disclosures are not configured.
+	ErrDisclosuresDisabled = errors.New("payment disclosures are not enabled")
+)
+
+// DisclosureRepository keeps disclosures as issued.
+type DisclosureRepository interface {
+	Create(ctx context.Context, d *domain.PaymentDisclosure) error
+	// Find returns the disclosure, or nil.
+	Find(ctx context.Context, id uuid.UUID) (*domain.PaymentDisclosure, error)
+	// FindByTransaction returns the disclosure a payment was made under, or
+	// nil.
+	FindByTransaction(ctx context.Context, txID uuid.UUID) (*domain.PaymentDisclosure, error)
+	// Attach links the disclosure to the payment made under it, reporting
+	// false when it was already linked to one.
+	Attach(ctx context.Context, id, txID uuid.UUID) (bool, error)
+}
+
+// DeliveryEstimator estimates when a payment between two currencies that is
+// ready to settle at readyAt will have settled.
+type DeliveryEstimator interface {
+	SettledBy(from, to domain.Currency, readyAt time.Time) time.Time
+}
+
+// WithDisclosures lets senders see a payment's cost, exchange rate and
+// delivery date before they make it, and pay under that disclosure for
+// validity after it is issued. Without an estimator, payments are taken to
+// settle as soon as they are ready.
+func (s *Service) WithDisclosures(repo DisclosureRepository, eta DeliveryEstimator, validity time.Duration) *Service {
+	s.disclosures = repo
+	s.delivery = eta
+	s.disclosureValidity = validity
+	return s
+}
+
+// DisclosureRequest describes a payment the sender is considering; it
+// identifies the receiver as InitiatePaymentRequest does.
+type DisclosureRequest struct {
+	SenderID              uuid.UUID       `json:"-"`
+	ReceiverID            uuid.UUID       `json:"receiver_id"`
+	ReceiverWalletAddress string          `json:"receiver_wallet_number"`
+	Amount                decimal.Decimal `json:"amount"`
+	Currency              domain.Currency `json:"currency"`
+	DestinationCurrency   domain.Currency `json:"destination_currency"`
+	Channel               string          `json:"channel"`
+}
+
+// Disclose works out what the payment would cost at today's fees and rates,
+// what the recipient would receive and by when, and keeps the disclosure so
+// the payment can be made under it.
+func (s *Service) Disclose(ctx context.Context, req *DisclosureRequest) (*domain.PaymentDisclosure, error) {
+	if s.disclosures == nil {
+		return nil, ErrDisclosuresDisabled
+	}
+	if !req.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidDisclosure)
+	}
+	if req.Currency == "" {
+		return nil, fmt.Errorf("%w: currency is required", ErrInvalidDisclosure)
+	}
+	if req.ReceiverWalletAddress == "" && req.ReceiverID == uuid.Nil {
+		return nil, fmt.Errorf("%w: receiver_wallet_number or receiver_id is required", ErrInvalidDisclosure)
+	}
+
+	parties, err := s.priceParties(ctx, req.SenderID, req.ReceiverID, req.ReceiverWalletAddress, req.Currency, req.DestinationCurrency)
+	if err != nil {
+		return nil, err
+	}
+	senderWallet, receiverWallet := parties.SenderWallet, parties.ReceiverWallet
+	recipient, err := s.userRepo.FindByID(ctx, receiverWallet.UserID)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to load recipient")
+	}
+
+	fee, err := s.quoteFee(ctx, parties.Sender, req.Amount, senderWallet.Currency, receiverWallet.Currency, req.Channel)
+	if err != nil {
+		return nil, err
+	}
+	rate, midRate, err := s.priceRate(ctx, senderWallet.Currency, receiverWallet.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	d := &domain.PaymentDisclosure{
+		ID:               uuid.New(),
+		UserID:           req.SenderID,
+		SenderWalletID:   senderWallet.ID,
+		ReceiverWalletID: receiverWallet.ID,
+		RecipientName:    strings.TrimSpace(recipient.FirstName + " " + recipient.LastName),
+		TransferAmount:   req.Amount,
+		TransferCurrency: senderWallet.Currency,
+		TransferFees:     fee.Fee,
+		TransferTaxes:    decimal.Zero,
+		ExchangeRate:     rate.Round(6),
+		MidMarketRate:    midRate.Round(6),
+		ReceiveAmount:    req.Amount.Mul(rate).Round(2),
+		ReceiveCurrency:  receiverWallet.Currency,
+		OtherFees:        decimal.Zero,
+		AvailableBy:      s.availableBy(senderWallet.Currency, receiverWallet.Currency, now),
+		SubjectToReview:  s.riskEngine.RequiresAdminApproval(req.Amount),
+		RightsNotice:     rightsNotice,
+		IssuedAt:         now,
+		ExpiresAt:        now.Add(s.disclosureValidity),
+	}
+	d.TotalCost = d.TransferAmount.Add(d.TransferFees).Add(d.TransferTaxes)
+	d.TotalToRecipient = d.ReceiveAmount.Sub(d.OtherFees)
+	d.FXMarginAmount = req.Amount.Mul(midRate).Round(2).Sub(d.ReceiveAmount)
+	if midRate.IsPositive() {
+		d.FXMarginPercent = midRate.Sub(rate).Div(midRate).Mul(decimal.NewFromInt(100)).Round(4)
+	}
+
+	if err := s.disclosures.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// availableBy is when a payment from one currency to another initiated at
+// now is expected to have settled: after its corridor's cut-off window next
+// opens if it is closed, and then as the settlement mode allows.
+func (s *Service) availableBy(from, to domain.Currency, now time.Time) time.Time {
+	ready := now
+	if w, ok := s.corridorWindow(from, to); ok {
+		if next, open := w.NextOpen(now); !open {
+			ready = next
+		}
+	}
+	if s.delivery == nil {
+		return ready
+	}
+	return s.delivery.SettledBy(from, to, ready)
+}
+
+// GetDisclosure returns a disclosure issued to userID.
+func (s *Service) GetDisclosure(ctx context.Context, userID, id uuid.UUID) (*domain.PaymentDisclosure, error) {
+	if s.disclosures == nil {
+		return nil, ErrDisclosuresDisabled
+	}
+	d, err := s.disclosures.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.UserID != userID {
+		return nil, ErrDisclosureNotFound
+	}
+	return d, nil
+}
+
+// TransactionDisclosure returns the disclosure a payment was made under. A
+// non-nil userID must be its sender.
+func (s *Service) TransactionDisclosure(ctx context.Context, txID, userID uuid.UUID) (*domain.PaymentDisclosure, error) {
+	if s.disclosures == nil {
+		return nil, ErrDisclosuresDisabled
+	}
+	d, err := s.disclosures.FindByTransaction(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || (userID != uuid.Nil && d.UserID != userID) {
+		return nil, ErrDisclosureNotFound
+	}
+	return d, nil
+}
+
+// holdToDisclosure makes tx under the sender's disclosure id, recording it
+// on tx. The payment is refused if the disclosure expired or was used, is
+// for another payment, or promised a lower fee or a larger amount received
+// than tx now gets: the sender then asks for a new disclosure.
+func (s *Service) holdToDisclosure(ctx context.Context, id uuid.UUID, tx *domain.Transaction) error {
+	if s.disclosures == nil {
+		return ErrDisclosuresDisabled
+	}
+	d, err := s.disclosures.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if d == nil || d.UserID != tx.SenderID {
+		return ErrDisclosureNotFound
+	}
+	switch {
+	case d.TransactionID != nil:
+		return fmt.Errorf("%w: it was already used", ErrDisclosureUnusable)
+	case d.IsExpired(time.Now()):
+		return fmt.Errorf("%w: it has expired, request a new one", ErrDisclosureUnusable)
+	case d.ReceiverWalletID != *tx.ReceiverWalletID || d.TransferCurrency != tx.Currency || !d.TransferAmount.Equal(tx.Amount):
+		return fmt.Errorf("%w: it is for a different payment", ErrDisclosureUnusable)
+	case tx.FeeAmount.Round(2).GreaterThan(d.TransferFees) || tx.ConvertedAmount.Round(2).LessThan(d.ReceiveAmount):
+		return fmt.Errorf("%w: fees or exchange rates have changed since, request a new one", ErrDisclosureUnusable)
+	}
+
+	attached, err := s.disclosures.Attach(ctx, d.ID, tx.ID)
+	if err != nil {
+		return err
+	}
+	if !attached {
+		return fmt.Errorf("%w: it was already used", ErrDisclosureUnusable)
+	}
+	metadata := make(domain.Metadata, len(tx.Metadata)+1)
+	for k, v := range tx.Metadata {
+		metadata[k] = v
+	}
+	metadata[disclosureKey] = d.ID.String()
+	tx.Metadata = metadata
+	return nil
+}
@@ -0,0 +1,157 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type memDisclosures struct {
+	items map[uuid.UUID]*domain.PaymentDisclosure
+}
+
+func (m *memDisclosures) Create(ctx context.Context, d *domain.PaymentDisclosure) error {
+	cp := *d
+	m.items[d.ID] = &cp
+	return nil
+}
+
+func (m *memDisclosures) Find(ctx context.Context, id uuid.UUID) (*domain.PaymentDisclosure, error) {
+	return m.items[id], nil
+}
+
+func (m *memDisclosures) FindByTransaction(ctx context.Context, txID uuid.UUID) (*domain.PaymentDisclosure, error) {
+	for _, d := range m.items {
+		if d.TransactionID != nil && *d.TransactionID == txID {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memDisclosures) Attach(ctx context.Context, id, txID uuid.UUID) (bool, error) {
+	d := m.items[id]
+	if d == nil || d.TransactionID != nil {
+		return false, nil
+	}
+	d.TransactionID = &txID
+	return true, nil
+}
+
+// settlesIn settles every payment a fixed time after it is ready.
+type settlesIn time.Duration
+
+func (d settlesIn) SettledBy(from, to domain.Currency, readyAt time.Time) time.Time {
+	return readyAt.Add(time.Duration(d))
+}
+
+func newDisclosureService() (*Service, *memDisclosures, *domain.Wallet) {
+	sender := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.MWK}
+	receiver := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.ZAR}
+
+	wallets := new(MockWalletRepository)
+	wallets.On("FindPaymentParties", mock.Anything, mock.Anything).
+		Return(&domain.PaymentParties{Sender: &domain.User{ID: sender.UserID}, SenderWallet: sender, ReceiverWallet: receiver}, nil)
+	forex := new(MockForexService)
+	forex.On("GetRate", mock.Anything, domain.MWK, domain.ZAR).
+		Return(&domain.ExchangeRate{Rate: decimal.RequireFromString("0.011"), SellRate: decimal.RequireFromString("0.0105")}, nil)
+	users := new(MockUserRepository)
+	users.On("FindByID", mock.Anything, receiver.UserID).Return(&domain.User{FirstName: "Thandi", LastName: "Nkosi"}, nil)
+
+	disclosures := &memDisclosures{items: map[uuid.UUID]*domain.PaymentDisclosure{}}
+	s := NewService(nil, wallets, forex, nil, users, nil, nil, nil, logger.NewNop(), nil).
+		WithDisclosures(disclosures, settlesIn(time.Hour), 15*time.Minute)
+	return s, disclosures, receiver
+}
+
+func TestDisclose(t *testing.T) {
+	ctx := context.Background()
+	s, disclosures, receiver := newDisclosureService()
+	sender := uuid.New()
+
+	_, err := s.Disclose(ctx, &DisclosureRequest{SenderID: sender, Amount: decimal.NewFromInt(10000), Currency: domain.MWK})
+	assert.ErrorIs(t, err, ErrInvalidDisclosure, "no receiver")
+
+	before := time.Now()
+	d, err := s.Disclose(ctx, &DisclosureRequest{
+		SenderID:            sender,
+		ReceiverID:          receiver.UserID,
+		Amount:              decimal.NewFromInt(10000),
+		Currency:            domain.MWK,
+		DestinationCurrency: domain.ZAR,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Thandi Nkosi", d.RecipientName)
+	assert.Equal(t, receiver.ID, d.ReceiverWalletID)
+	assert.Equal(t, "150", d.TransferFees.String(), "the standard 1.5% fee")
+	assert.Equal(t, "10150", d.TotalCost.String())
+	assert.Equal(t, "105", d.ReceiveAmount.String())
+	assert.Equal(t, "105", d.TotalToRecipient.String())
+	assert.Equal(t, "4.5455", d.FXMarginPercent.String(), "(0.011 - 0.0105) / 0.011")
+	assert.Equal(t, "5", d.FXMarginAmount.String(), "110 ZAR at the mid-market rate")
+	assert.False(t, d.SubjectToReview)
+	assert.NotEmpty(t, d.RightsNotice)
+	assert.WithinDuration(t, before.Add(time.Hour), d.AvailableBy, 5*time.Second)
+	assert.Equal(t, 15*time.Minute, d.ExpiresAt.Sub(d.IssuedAt))
+	assert.Contains(t, disclosures.items, d.ID, "kept as issued")
+
+	got, err := s.GetDisclosure(ctx, sender, d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, d.ID, got.ID)
+	_, err = s.GetDisclosure(ctx, uuid.New(), d.ID)
+	assert.ErrorIs(t, err, ErrDisclosureNotFound, "someone else's disclosure")
+}
+
+func TestHoldToDisclosure(t *testing.T) {
+	ctx := context.Background()
+	s, disclosures, receiver := newDisclosureService()
+	sender := uuid.New()
+	d, err := s.Disclose(ctx, &DisclosureRequest{SenderID: sender, ReceiverID: receiver.UserID, Amount: decimal.NewFromInt(10000), Currency: domain.MWK, DestinationCurrency: domain.ZAR})
+	require.NoError(t, err)
+
+	transfer := func(amount, fee, converted string) *domain.Transaction {
+		return &domain.Transaction{
+			ID:               uuid.New(),
+			SenderID:         sender,
+			ReceiverWalletID: &receiver.ID,
+			Amount:           decimal.RequireFromString(amount),
+			Currency:         domain.MWK,
+			FeeAmount:        decimal.RequireFromString(fee),
+			ConvertedAmount:  decimal.RequireFromString(converted),
+			Metadata:         domain.Metadata{"source": "app"},
+		}
+	}
+
+	assert.ErrorIs(t, s.holdToDisclosure(ctx, uuid.New(), transfer("10000", "150", "105")), ErrDisclosureNotFound)
+	assert.ErrorIs(t, s.holdToDisclosure(ctx, d.ID, transfer("9000", "135", "94.5")), ErrDisclosureUnusable, "another amount")
+	assert.ErrorIs(t, s.holdToDisclosure(ctx, d.ID, transfer("10000", "150", "104.99")), ErrDisclosureUnusable, "the rate moved against the sender")
+	assert.ErrorIs(t, s.holdToDisclosure(ctx, d.ID, transfer("10000", "200", "105")), ErrDisclosureUnusable, "a higher fee")
+
+	tx := transfer("10000", "150", "105.2")
+	require.NoError(t, s.holdToDisclosure(ctx, d.ID, tx), "better terms than disclosed are fine")
+	assert.Equal(t, d.ID.String(), tx.Metadata[disclosureKey])
+	assert.Equal(t, "app", tx.Metadata["source"])
+	linked, err := s.TransactionDisclosure(ctx, tx.ID, sender)
+	require.NoError(t, err)
+	assert.Equal(t, d.ID, linked.ID)
+	_, err = s.TransactionDisclosure(ctx, tx.ID, uuid.New())
+	assert.ErrorIs(t, err, ErrDisclosureNotFound, "only the sender sees it")
+	_, err = s.TransactionDisclosure(ctx, tx.ID, uuid.Nil)
+	assert.NoError(t, err, "staff see any payment's disclosure")
+
+	assert.ErrorIs(t, s.holdToDisclosure(ctx, d.ID, transfer("10000", "150", "105")), ErrDisclosureUnusable, "already used")
+
+	expired, err := s.Disclose(ctx, &DisclosureRequest{SenderID: sender, ReceiverID: receiver.UserID, Amount: decimal.NewFromInt(10000), Currency: domain.MWK, DestinationCurrency: domain.ZAR})
+	require.NoError(t, err)
+	disclosures.items[expired.ID].ExpiresAt = time.Now().Add(-time.Second)
+	assert.ErrorIs(t, s.holdToDisclosure(ctx, expired.ID, transfer("10000", "150", "105")), ErrDisclosureUnusable)
+}
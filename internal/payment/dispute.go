@@ -63,6 +63,7 @@ func (s *Service) InitiateDispute(ctx context.Context, req InitiateDisputeReques
 	if err != nil {
 		return err
 	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventDisputed, domain.Metadata{"reason": req.Reason})
 
 	// Notify parties
 	// Note: checking errors on notification is optional for non-critical path, but good practice.
@@ -120,6 +121,7 @@ func (s *Service) ResolveDispute(ctx context.Context, req ResolveDisputeRequest)
 			TransactionID:     tx.ID,
 			EventType:         "dispute_reversal",
 			Description:       fmt.Sprintf("Reversal for dispute on %s", tx.Reference),
+			IdempotencyKey:    "dispute_reversal:" + tx.ID.String(),
 		}
 
 		if err := s.ledgerService.PostTransaction(ctx, reversalPosting); err != nil {
@@ -4,14 +4,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	"kyd/internal/ledger"
+	"kyd/pkg/logger"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
+var (
+	// ErrInvalidEscrow is returned for an escrow that cannot be created,
+	// such as one that has already expired.
+	ErrInvalidEscrow = errors.New("invalid escrow")
+	// ErrEscrowNotFound is returned for a transaction that is not an escrow.
+	ErrEscrowNotFound = errors.New("escrow not found")
+	// ErrEscrowNotHeld is returned when an escrow has already been released
+	// or refunded.
+	ErrEscrowNotHeld = errors.New("transaction is not in escrow/reserved state")
+	// ErrEscrowForbidden is returned when the caller may not act on an
+	// escrow in the way asked.
+	ErrEscrowForbidden = errors.New("not allowed to act on this escrow")
+	// ErrEscrowsDisabled is returned when no escrow repository is set.
+	ErrEscrowsDisabled = errors.New("escrow is not enabled")
+	// ErrEscrowRefused wraps the check an escrow failed, one of those every
+	// payment passes.
+	ErrEscrowRefused = errors.New("escrow refused")
+)
+
 // EscrowRequest defines the parameters for creating an escrow transaction
 type EscrowRequest struct {
 	SenderID    uuid.UUID       `json:"sender_id" validate:"required"`
@@ -21,29 +44,133 @@ type EscrowRequest struct {
 	Condition   string          `json:"condition" validate:"required"`
 	Expiry      time.Time       `json:"expiry" validate:"required"`
 	Description string          `json:"description"`
+	Location    string          `json:"location"`
+	StepUp      *StepUp         `json:"step_up,omitempty"` // re-authentication for escrows that need it
+}
+
+// EscrowRepository finds escrows and moves them out of the reserved state.
+type EscrowRepository interface {
+	// ListEscrows returns the escrows the user sent or receives, newest
+	// first, and how many there are.
+	ListEscrows(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, int, error)
+	// FindExpiredEscrows returns escrows still held after their expiry.
+	FindExpiredEscrows(ctx context.Context, now time.Time, limit int) ([]*domain.Transaction, error)
+	// TransitionEscrow moves a held escrow to status, reporting false when it
+	// was no longer held, so that two callers cannot both pay it out.
+	TransitionEscrow(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error)
+}
+
+// EscrowLedger moves escrowed funds, each movement at most once under its
+// idempotency key; *ledger.Service satisfies it.
+type EscrowLedger interface {
+	HoldEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error)
+	ReleaseEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error)
+	RefundEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error)
+}
+
+// escrowChannel is the channel escrows are made on.
+const escrowChannel = "api"
+
+// WithEscrows enables escrow payments, held and paid out through l.
+func (s *Service) WithEscrows(repo EscrowRepository, l EscrowLedger) *Service {
+	s.escrows = repo
+	s.escrowLedger = l
+	return s
+}
+
+// escrowPosting is the ledger posting of an escrow's event. The key is
+// derived from the escrow, so each event is posted once however often it
+// is retried.
+func escrowPosting(tx *domain.Transaction, event string) *ledger.EscrowPosting {
+	return &ledger.EscrowPosting{
+		TransactionID:    tx.ID,
+		SenderWalletID:   *tx.SenderWalletID,
+		ReceiverWalletID: *tx.ReceiverWalletID,
+		Amount:           tx.Amount,
+		Currency:         tx.Currency,
+		IdempotencyKey:   event + ":" + tx.ID.String(),
+	}
+}
+
+func isEscrow(tx *domain.Transaction) bool {
+	t, _ := tx.Metadata["type"].(string)
+	return t == "ESCROW"
+}
+
+func escrowExpiry(tx *domain.Transaction) (time.Time, bool) {
+	v, ok := tx.Metadata["escrow_expiry"].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, err == nil
 }
 
-// CreateEscrow initiates a transaction but holds funds in a reserved state
+// CreateEscrow holds funds in the sender's wallet for a receiver. An
+// escrow passes the same checks as any payment before its funds are held,
+// and is paid out in its own currency, so the receiver must hold a wallet
+// in it.
 func (s *Service) CreateEscrow(ctx context.Context, req *EscrowRequest) (*PaymentResponse, error) {
-	// Reuse validation and risk checks from InitiatePayment logic where possible
-	// For brevity, we implement core logic here
+	if s.escrows == nil {
+		return nil, ErrEscrowsDisabled
+	}
 
 	// 1. Basic Validation
 	if req.Expiry.Before(time.Now()) {
-		return nil, errors.New("escrow expiry must be in the future")
+		return nil, fmt.Errorf("%w: escrow expiry must be in the future", ErrInvalidEscrow)
+	}
+	if req.SenderID == req.ReceiverID {
+		return nil, fmt.Errorf("%w: you cannot hold funds in escrow for yourself", ErrInvalidEscrow)
 	}
 
-	// 2. Fetch Wallets
-	senderWallet, err := s.walletRepo.FindByUserAndCurrency(ctx, req.SenderID, req.Currency)
+	// 2. Payment checks and wallets
+	senderWallet, receiverWallet, err := s.checkEscrow(ctx, req)
 	if err != nil {
-		return nil, errors.New("sender wallet not found for this currency")
+		return nil, err
 	}
+	return s.openEscrow(ctx, req, senderWallet, receiverWallet)
+}
 
-	receiverWallet, err := s.getReceiverWallet(ctx, req.ReceiverID, req.Currency, "")
+// checkEscrow runs the checks of InitiatePayment on an escrow and returns
+// both parties' wallets in the escrow's currency.
+func (s *Service) checkEscrow(ctx context.Context, req *EscrowRequest) (*domain.Wallet, *domain.Wallet, error) {
+	payment := &InitiatePaymentRequest{
+		SenderID:            req.SenderID,
+		ReceiverID:          req.ReceiverID,
+		Amount:              req.Amount,
+		Currency:            req.Currency,
+		DestinationCurrency: req.Currency,
+		Description:         req.Description,
+		Channel:             escrowChannel,
+		Location:            req.Location,
+		StepUp:              req.StepUp,
+	}
+	if err := s.riskEngine.CheckGlobalCircuitBreaker(); err != nil {
+		return nil, nil, err
+	}
+	pre, err := s.preloadInitiation(ctx, payment)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pre.parties.ReceiverWallet == nil {
+		return nil, nil, fmt.Errorf("%w: receiver has no %s wallet", ErrInvalidEscrow, req.Currency)
+	}
+	if err := s.screenPayment(payment, pre); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrEscrowRefused, err)
+	}
+	senderWallet, receiverWallet, err := s.clearPayment(ctx, payment, pre)
 	if err != nil {
-		return nil, errors.New("receiver wallet not found")
+		return nil, nil, fmt.Errorf("%w: %w", ErrEscrowRefused, err)
+	}
+	if senderWallet.Currency != req.Currency || receiverWallet.Currency != req.Currency {
+		return nil, nil, fmt.Errorf("%w: both wallets must be in %s", ErrInvalidEscrow, req.Currency)
 	}
+	return senderWallet, receiverWallet, nil
+}
 
+// openEscrow records a checked escrow and holds its funds through the
+// ledger.
+func (s *Service) openEscrow(ctx context.Context, req *EscrowRequest, senderWallet, receiverWallet *domain.Wallet) (*PaymentResponse, error) {
 	// 3. Create Transaction Record (Status: RESERVED)
 	tx := &domain.Transaction{
 		ID:                uuid.New(),
@@ -54,19 +181,20 @@ func (s *Service) CreateEscrow(ctx context.Context, req *EscrowRequest) (*Paymen
 		ReceiverWalletID:  &receiverWallet.ID,
 		Amount:            req.Amount,
 		Currency:          req.Currency,
-		ConvertedAmount:   req.Amount, // Assuming same currency for simplicity in escrow
+		ConvertedAmount:   req.Amount,
 		ConvertedCurrency: req.Currency,
 		ExchangeRate:      decimal.NewFromInt(1),
+		NetAmount:         req.Amount,
 		Status:            domain.TransactionStatusReserved, // CRITICAL: Reserved, not Completed
 		TransactionType:   domain.TransactionTypePayment,
-		Channel:           "api",
+		Channel:           escrowChannel,
 		Description:       req.Description,
 		InitiatedAt:       time.Now(),
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 		Metadata: domain.Metadata{
 			"escrow_condition": req.Condition,
-			"escrow_expiry":    req.Expiry.Format(time.RFC3339),
+			"escrow_expiry":    req.Expiry.UTC().Format(time.RFC3339),
 			"type":             "ESCROW",
 		},
 	}
@@ -75,11 +203,15 @@ func (s *Service) CreateEscrow(ctx context.Context, req *EscrowRequest) (*Paymen
 		return nil, err
 	}
 
-	// 4. Reserve Funds (Move from Available to Reserved)
-	if err := s.walletRepo.ReserveFunds(ctx, senderWallet.ID, req.Amount); err != nil {
+	// 4. Hold Funds (Move from Available to Reserved)
+	if _, err := s.escrowLedger.HoldEscrow(ctx, escrowPosting(tx, ledger.EscrowHold)); err != nil {
 		tx.Status = domain.TransactionStatusFailed
-		s.repo.Update(ctx, tx)
-		return nil, fmt.Errorf("failed to reserve funds: %v", err)
+		tx.StatusReason = "We could not hold the funds for your escrow."
+		tx.UpdatedAt = time.Now()
+		if updateErr := s.repo.Update(ctx, tx); updateErr != nil {
+			s.logger.Error("Failed to update escrow status to failed", map[string]interface{}{"error": updateErr.Error(), "tx_id": tx.ID})
+		}
+		return nil, fmt.Errorf("failed to reserve funds: %w", err)
 	}
 
 	s.logger.Info("Escrow created", map[string]interface{}{
@@ -95,75 +227,185 @@ func (s *Service) CreateEscrow(ctx context.Context, req *EscrowRequest) (*Paymen
 	}, nil
 }
 
-// ReleaseEscrow releases funds to the receiver
-func (s *Service) ReleaseEscrow(ctx context.Context, txID uuid.UUID, userID uuid.UUID) error {
+// ListEscrows returns the escrows the user sent or receives.
+func (s *Service) ListEscrows(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, int, error) {
+	if s.escrows == nil {
+		return nil, 0, ErrEscrowsDisabled
+	}
+	return s.escrows.ListEscrows(ctx, userID, limit, offset)
+}
+
+// heldEscrow loads an escrow the user is a party to that is still held.
+func (s *Service) heldEscrow(ctx context.Context, txID, userID uuid.UUID) (*domain.Transaction, error) {
+	if s.escrows == nil {
+		return nil, ErrEscrowsDisabled
+	}
 	tx, err := s.repo.FindByID(ctx, txID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// Strangers get the same answer as for a missing escrow, so that escrow
+	// IDs cannot be probed.
+	if !isEscrow(tx) || (tx.SenderID != userID && tx.ReceiverID != userID) {
+		return nil, ErrEscrowNotFound
 	}
-
 	if tx.Status != domain.TransactionStatusReserved {
-		return errors.New("transaction is not in escrow/reserved state")
+		return nil, ErrEscrowNotHeld
 	}
+	return tx, nil
+}
 
-	// Only Sender or Admin (or Oracle) should release.
-	// For now, let's allow Sender to release (satisfaction) or Receiver (if condition met - handled by oracle usually).
-	// We'll assume the caller (userID) has authority.
-	if tx.SenderID != userID && tx.ReceiverID != userID { // Simplified auth
-		return errors.New("unauthorized to release escrow")
+// ReleaseEscrow pays an escrow out to the receiver. Only the sender can
+// release, once satisfied that the condition is met; the receiver can never
+// release funds to themselves.
+func (s *Service) ReleaseEscrow(ctx context.Context, txID uuid.UUID, userID uuid.UUID) error {
+	tx, err := s.heldEscrow(ctx, txID, userID)
+	if err != nil {
+		return err
 	}
-
-	// Credit Receiver
-	if tx.ReceiverWalletID == nil {
-		return errors.New("receiver wallet missing")
+	if tx.SenderID != userID {
+		return fmt.Errorf("%w: only the sender can release an escrow", ErrEscrowForbidden)
 	}
-	if err := s.walletRepo.CreditWallet(ctx, *tx.ReceiverWalletID, tx.ConvertedAmount); err != nil {
-		return fmt.Errorf("failed to credit receiver: %v", err)
+	if tx.SenderWalletID == nil || tx.ReceiverWalletID == nil {
+		return errors.New("escrow wallet missing")
 	}
 
-	// Update Status
-	tx.Status = domain.TransactionStatusCompleted
+	// Claim the escrow before moving money, so that a concurrent release or
+	// refund finds it no longer held.
 	now := time.Now()
+	tx.Status = domain.TransactionStatusCompleted
 	tx.CompletedAt = &now
 	tx.UpdatedAt = now
+	if ok, err := s.escrows.TransitionEscrow(ctx, tx, domain.TransactionStatusReserved); err != nil {
+		return err
+	} else if !ok {
+		return ErrEscrowNotHeld
+	}
+
+	// Pay the held funds out of the sender's wallet to the receiver in one
+	// posting, which a retry of the release does not repeat.
+	if _, err := s.escrowLedger.ReleaseEscrow(ctx, escrowPosting(tx, ledger.EscrowRelease)); err != nil {
+		s.unclaimEscrow(ctx, tx, domain.TransactionStatusCompleted)
+		return fmt.Errorf("failed to release escrow funds: %w", err)
+	}
 
-	return s.repo.Update(ctx, tx)
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventDelivered, domain.Metadata{"reason": "Escrow released to the receiver."})
+	return nil
 }
 
-// RefundEscrow returns funds to the sender (e.g. expiry or cancellation)
+// RefundEscrow returns an escrow's funds to the sender. The sender can
+// cancel at any time; the receiver can only hand the funds back once the
+// escrow has expired, and the expiry worker does so automatically.
 func (s *Service) RefundEscrow(ctx context.Context, txID uuid.UUID, userID uuid.UUID) error {
-	tx, err := s.repo.FindByID(ctx, txID)
+	tx, err := s.heldEscrow(ctx, txID, userID)
 	if err != nil {
 		return err
 	}
+	if expiry, ok := escrowExpiry(tx); ok && time.Now().Before(expiry) && tx.SenderID != userID {
+		return fmt.Errorf("%w: escrow has not expired yet", ErrEscrowForbidden)
+	}
+	return s.refundEscrow(ctx, tx, "Escrow Refunded")
+}
 
-	if tx.Status != domain.TransactionStatusReserved {
-		return errors.New("transaction is not in escrow/reserved state")
+func (s *Service) refundEscrow(ctx context.Context, tx *domain.Transaction, reason string) error {
+	if tx.SenderWalletID == nil || tx.ReceiverWalletID == nil {
+		return errors.New("escrow wallet missing")
+	}
+	tx.Status = domain.TransactionStatusCancelled
+	tx.StatusReason = reason
+	tx.UpdatedAt = time.Now()
+	if ok, err := s.escrows.TransitionEscrow(ctx, tx, domain.TransactionStatusReserved); err != nil {
+		return err
+	} else if !ok {
+		return ErrEscrowNotHeld
 	}
 
-	// Validate Expiry or Permission
-	expiryStr, ok := tx.Metadata["escrow_expiry"].(string)
-	if ok {
-		expiry, _ := time.Parse(time.RFC3339, expiryStr)
-		if time.Now().Before(expiry) && tx.SenderID != userID {
-			return errors.New("escrow has not expired yet")
-		}
+	// Refund Sender: return the held funds to their available balance
+	if _, err := s.escrowLedger.RefundEscrow(ctx, escrowPosting(tx, ledger.EscrowRefund)); err != nil {
+		s.unclaimEscrow(ctx, tx, domain.TransactionStatusCancelled)
+		return fmt.Errorf("failed to refund sender: %w", err)
+	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventCancelled, domain.Metadata{"reason": reason})
+	return nil
+}
+
+// unclaimEscrow puts an escrow back on hold after its funds failed to move.
+func (s *Service) unclaimEscrow(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) {
+	tx.Status = domain.TransactionStatusReserved
+	tx.StatusReason = ""
+	tx.CompletedAt = nil
+	tx.UpdatedAt = time.Now()
+	if _, err := s.escrows.TransitionEscrow(ctx, tx, from); err != nil {
+		s.logger.Error("Failed to return escrow to held", map[string]interface{}{"tx_id": tx.ID, "error": err.Error()})
 	}
+}
 
-	// Refund Sender
-	if tx.SenderWalletID == nil {
-		return errors.New("sender wallet missing")
+// RefundExpiredEscrows refunds escrows whose expiry has passed, returning
+// how many were refunded.
+func (s *Service) RefundExpiredEscrows(ctx context.Context, now time.Time) (int, error) {
+	if s.escrows == nil {
+		return 0, ErrEscrowsDisabled
+	}
+	txs, err := s.escrows.FindExpiredEscrows(ctx, now, 100)
+	if err != nil {
+		return 0, err
 	}
-	if err := s.walletRepo.CreditWallet(ctx, *tx.SenderWalletID, tx.Amount); err != nil {
-		return fmt.Errorf("failed to refund sender: %v", err)
+	refunded := 0
+	for _, tx := range txs {
+		if err := s.refundEscrow(ctx, tx, "Escrow expired"); err != nil {
+			if !errors.Is(err, ErrEscrowNotHeld) {
+				s.logger.Error("Failed to refund expired escrow", map[string]interface{}{"tx_id": tx.ID, "error": err.Error()})
+			}
+			continue
+		}
+		refunded++
 	}
+	return refunded, nil
+}
 
-	// Update Status
-	tx.Status = domain.TransactionStatusCancelled
-	reason := "Escrow Refunded"
-	tx.StatusReason = reason
-	now := time.Now()
-	tx.UpdatedAt = now
+// EscrowExpiryWorker refunds expired escrows in the background.
+type EscrowExpiryWorker struct {
+	service  *Service
+	interval time.Duration
+	logger   logger.Logger
+	pulse    heartbeat.Pulse
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewEscrowExpiryWorker(service *Service, interval time.Duration, log logger.Logger) *EscrowExpiryWorker {
+	return &EscrowExpiryWorker{service: service, interval: interval, logger: log, pulse: heartbeat.Nop, stop: make(chan struct{})}
+}
+
+// WithHeartbeat beats p after every pass.
+func (w *EscrowExpiryWorker) WithHeartbeat(p heartbeat.Pulse) *EscrowExpiryWorker {
+	w.pulse = p
+	return w
+}
+
+func (w *EscrowExpiryWorker) Start() {
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := w.service.RefundExpiredEscrows(context.Background(), time.Now())
+				if err != nil {
+					w.logger.Error("Failed to refund expired escrows", map[string]interface{}{"error": err.Error()})
+				} else if n > 0 {
+					w.logger.Info("Refunded expired escrows", map[string]interface{}{"count": n})
+				}
+				w.pulse.Beat()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	w.logger.Info("Escrow expiry worker started", map[string]interface{}{"interval": w.interval.String()})
+}
 
-	return s.repo.Update(ctx, tx)
+func (w *EscrowExpiryWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
 }
@@ -0,0 +1,299 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memEscrows keeps transactions in memory and moves them between statuses
+// the way the postgres repository does.
+type memEscrows struct {
+	Repository
+	txs map[uuid.UUID]*domain.Transaction
+}
+
+func (m *memEscrows) Create(ctx context.Context, tx *domain.Transaction) error {
+	cp := *tx
+	m.txs[tx.ID] = &cp
+	return nil
+}
+
+func (m *memEscrows) Update(ctx context.Context, tx *domain.Transaction) error {
+	return m.Create(ctx, tx)
+}
+
+func (m *memEscrows) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	tx, ok := m.txs[id]
+	if !ok {
+		return nil, pkgerrors.ErrTransactionNotFound
+	}
+	cp := *tx
+	return &cp, nil
+}
+
+func (m *memEscrows) ListEscrows(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, int, error) {
+	var out []*domain.Transaction
+	for _, tx := range m.txs {
+		if isEscrow(tx) && (tx.SenderID == userID || tx.ReceiverID == userID) {
+			out = append(out, tx)
+		}
+	}
+	return out, len(out), nil
+}
+
+func (m *memEscrows) FindExpiredEscrows(ctx context.Context, now time.Time, limit int) ([]*domain.Transaction, error) {
+	var out []*domain.Transaction
+	for _, tx := range m.txs {
+		if expiry, ok := escrowExpiry(tx); ok && tx.Status == domain.TransactionStatusReserved && !expiry.After(now) {
+			cp := *tx
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memEscrows) TransitionEscrow(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error) {
+	stored, ok := m.txs[tx.ID]
+	if !ok || stored.Status != from {
+		return false, nil
+	}
+	stored.Status = tx.Status
+	stored.StatusReason = tx.StatusReason
+	return true, nil
+}
+
+// escrowWallets tracks each wallet's available and reserved balance.
+type escrowWallets struct {
+	WalletRepository
+	wallets   map[uuid.UUID]*domain.Wallet
+	available map[uuid.UUID]decimal.Decimal
+	reserved  map[uuid.UUID]decimal.Decimal
+	posted    map[string]bool
+}
+
+func (w *escrowWallets) FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error) {
+	for _, wl := range w.wallets {
+		if wl.UserID == userID && wl.Currency == currency {
+			return wl, nil
+		}
+	}
+	return nil, pkgerrors.ErrWalletNotFound
+}
+
+func (w *escrowWallets) FindByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Wallet, error) {
+	var out []*domain.Wallet
+	for _, wl := range w.wallets {
+		if wl.UserID == userID {
+			out = append(out, wl)
+		}
+	}
+	return out, nil
+}
+
+func (w *escrowWallets) ReserveFunds(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	if w.available[id].LessThan(amount) {
+		return pkgerrors.ErrInsufficientBalance
+	}
+	w.available[id] = w.available[id].Sub(amount)
+	w.reserved[id] = w.reserved[id].Add(amount)
+	return nil
+}
+
+func (w *escrowWallets) ReleaseReservedFunds(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	w.reserved[id] = w.reserved[id].Sub(amount)
+	w.available[id] = w.available[id].Add(amount)
+	return nil
+}
+
+func (w *escrowWallets) DebitReservedFunds(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	w.reserved[id] = w.reserved[id].Sub(amount)
+	return nil
+}
+
+func (w *escrowWallets) CreditWallet(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	w.available[id] = w.available[id].Add(amount)
+	return nil
+}
+
+// HoldEscrow, ReleaseEscrow and RefundEscrow make escrowWallets the escrow
+// ledger too, posting each key once.
+func (w *escrowWallets) HoldEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error) {
+	return w.post(p, func() error { return w.ReserveFunds(ctx, p.SenderWalletID, p.Amount) })
+}
+
+func (w *escrowWallets) ReleaseEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error) {
+	return w.post(p, func() error {
+		w.reserved[p.SenderWalletID] = w.reserved[p.SenderWalletID].Sub(p.Amount)
+		w.available[p.ReceiverWalletID] = w.available[p.ReceiverWalletID].Add(p.Amount)
+		return nil
+	})
+}
+
+func (w *escrowWallets) RefundEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error) {
+	return w.post(p, func() error { return w.ReleaseReservedFunds(ctx, p.SenderWalletID, p.Amount) })
+}
+
+func (w *escrowWallets) post(p *ledger.EscrowPosting, move func() error) (*ledger.PostingReceipt, error) {
+	if w.posted[p.IdempotencyKey] {
+		return &ledger.PostingReceipt{IdempotencyKey: p.IdempotencyKey, Replayed: true}, nil
+	}
+	if err := move(); err != nil {
+		return nil, err
+	}
+	w.posted[p.IdempotencyKey] = true
+	return &ledger.PostingReceipt{IdempotencyKey: p.IdempotencyKey}, nil
+}
+
+func newEscrowService() (*Service, *escrowWallets, *domain.Wallet, *domain.Wallet) {
+	buyer := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.MWK}
+	seller := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.MWK}
+	wallets := &escrowWallets{
+		wallets:   map[uuid.UUID]*domain.Wallet{buyer.ID: buyer, seller.ID: seller},
+		available: map[uuid.UUID]decimal.Decimal{buyer.ID: decimal.NewFromInt(1000)},
+		reserved:  map[uuid.UUID]decimal.Decimal{},
+		posted:    map[string]bool{},
+	}
+	repo := &memEscrows{txs: map[uuid.UUID]*domain.Transaction{}}
+	s := NewService(repo, wallets, nil, nil, nil, nil, nil, nil, logger.NewNop(), nil).WithEscrows(repo, wallets)
+	return s, wallets, buyer, seller
+}
+
+// createEscrow opens an escrow that passed its checks; see
+// TestCreateEscrow_PaymentChecks for those.
+func createEscrow(t *testing.T, s *Service, buyer, seller *domain.Wallet, expiry time.Time) uuid.UUID {
+	t.Helper()
+	resp, err := s.openEscrow(context.Background(), &EscrowRequest{
+		SenderID:   buyer.UserID,
+		ReceiverID: seller.UserID,
+		Amount:     decimal.NewFromInt(300),
+		Currency:   domain.MWK,
+		Condition:  "goods delivered",
+		Expiry:     expiry,
+	}, buyer, seller)
+	require.NoError(t, err)
+	return resp.Transaction.ID
+}
+
+func TestCreateEscrow_Validation(t *testing.T) {
+	ctx := context.Background()
+	s, _, buyer, seller := newEscrowService()
+	req := func(expiry time.Time, receiver uuid.UUID, amount int64) *EscrowRequest {
+		return &EscrowRequest{SenderID: buyer.UserID, ReceiverID: receiver, Amount: decimal.NewFromInt(amount), Currency: domain.MWK, Condition: "x", Expiry: expiry}
+	}
+
+	_, err := s.CreateEscrow(ctx, req(time.Now().Add(-time.Minute), seller.UserID, 10))
+	assert.ErrorIs(t, err, ErrInvalidEscrow, "already expired")
+	_, err = s.CreateEscrow(ctx, req(time.Now().Add(time.Hour), buyer.UserID, 10))
+	assert.ErrorIs(t, err, ErrInvalidEscrow, "to self")
+	_, err = s.openEscrow(ctx, req(time.Now().Add(time.Hour), seller.UserID, 5000), buyer, seller)
+	assert.ErrorIs(t, err, pkgerrors.ErrInsufficientBalance)
+
+	disabled := NewService(nil, nil, nil, nil, nil, nil, nil, nil, logger.NewNop(), nil)
+	_, err = disabled.CreateEscrow(ctx, req(time.Now().Add(time.Hour), seller.UserID, 10))
+	assert.ErrorIs(t, err, ErrEscrowsDisabled)
+}
+
+func TestCreateEscrow_PaymentChecks(t *testing.T) {
+	ctx := context.Background()
+	escrow := func(w *sagaWorld) *EscrowRequest {
+		return &EscrowRequest{
+			SenderID:   w.sender.UserID,
+			ReceiverID: w.receiver.UserID,
+			Amount:     decimal.NewFromInt(100),
+			Currency:   domain.MWK,
+			Condition:  "goods delivered",
+			Expiry:     time.Now().Add(time.Hour),
+			Location:   "GB",
+		}
+	}
+	service := func(w *sagaWorld) *Service {
+		return w.service("", nil).WithEscrows(&memEscrows{txs: map[uuid.UUID]*domain.Transaction{}}, w.ledger)
+	}
+
+	w := newSagaWorld(domain.MWK)
+	resp, err := service(w).CreateEscrow(ctx, escrow(w))
+	require.NoError(t, err)
+	assert.Equal(t, "100", w.sender.ReservedBalance.String())
+	assert.True(t, w.ledger.posted["escrow_hold:"+resp.Transaction.ID.String()], "held through the ledger")
+
+	w = newSagaWorld(domain.MWK)
+	w.wallets.parties.Sender.KYCStatus = domain.KYCStatusPending
+	_, err = service(w).CreateEscrow(ctx, escrow(w))
+	assert.ErrorIs(t, err, ErrEscrowRefused, "KYC")
+	assert.Empty(t, w.txs.txs)
+
+	w = newSagaWorld(domain.MWK)
+	w.wallets.parties.Sender.CountryCode = "MW"
+	w.wallets.parties.ReceiverCountry = "IR"
+	_, err = service(w).WithJurisdictions(&restrictedTo{country: "IR"}).CreateEscrow(ctx, escrow(w))
+	assert.ErrorIs(t, err, pkgerrors.ErrJurisdictionRestricted)
+	assert.ErrorIs(t, err, ErrEscrowRefused)
+
+	// The escrow would pay out 1:1 into a wallet in another currency.
+	w = newSagaWorld(domain.ZAR)
+	_, err = service(w).CreateEscrow(ctx, escrow(w))
+	assert.ErrorIs(t, err, ErrInvalidEscrow)
+	assert.Empty(t, w.txs.txs)
+	assert.Equal(t, "1000", w.sender.AvailableBalance.String())
+}
+
+func TestReleaseEscrow(t *testing.T) {
+	ctx := context.Background()
+	s, wallets, buyer, seller := newEscrowService()
+	id := createEscrow(t, s, buyer, seller, time.Now().Add(time.Hour))
+	assert.True(t, wallets.reserved[buyer.ID].Equal(decimal.NewFromInt(300)))
+
+	assert.ErrorIs(t, s.ReleaseEscrow(ctx, id, uuid.New()), ErrEscrowNotFound, "strangers cannot see the escrow")
+	assert.ErrorIs(t, s.ReleaseEscrow(ctx, id, seller.UserID), ErrEscrowForbidden, "the receiver cannot release to themselves")
+
+	require.NoError(t, s.ReleaseEscrow(ctx, id, buyer.UserID))
+	assert.True(t, wallets.reserved[buyer.ID].IsZero())
+	assert.True(t, wallets.available[seller.ID].Equal(decimal.NewFromInt(300)))
+
+	assert.True(t, wallets.posted["escrow_release:"+id.String()])
+
+	assert.ErrorIs(t, s.ReleaseEscrow(ctx, id, buyer.UserID), ErrEscrowNotHeld, "released twice")
+	assert.ErrorIs(t, s.RefundEscrow(ctx, id, buyer.UserID), ErrEscrowNotHeld, "refunded after release")
+}
+
+func TestRefundEscrow(t *testing.T) {
+	ctx := context.Background()
+	s, wallets, buyer, seller := newEscrowService()
+	id := createEscrow(t, s, buyer, seller, time.Now().Add(time.Hour))
+
+	assert.ErrorIs(t, s.RefundEscrow(ctx, id, seller.UserID), ErrEscrowForbidden, "the receiver must wait for expiry")
+	require.NoError(t, s.RefundEscrow(ctx, id, buyer.UserID))
+	assert.True(t, wallets.available[buyer.ID].Equal(decimal.NewFromInt(1000)))
+	assert.True(t, wallets.reserved[buyer.ID].IsZero())
+
+	escrows, total, err := s.ListEscrows(ctx, seller.UserID, 50, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	assert.Equal(t, domain.TransactionStatusCancelled, escrows[0].Status)
+}
+
+func TestRefundExpiredEscrows(t *testing.T) {
+	ctx := context.Background()
+	s, wallets, buyer, seller := newEscrowService()
+	expired := createEscrow(t, s, buyer, seller, time.Now().Add(time.Minute))
+	held := createEscrow(t, s, buyer, seller, time.Now().Add(time.Hour))
+
+	n, err := s.RefundExpiredEscrows(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, wallets.reserved[buyer.ID].Equal(decimal.NewFromInt(300)))
+
+	assert.ErrorIs(t, s.ReleaseEscrow(ctx, expired, buyer.UserID), ErrEscrowNotHeld)
+	require.NoError(t, s.ReleaseEscrow(ctx, held, buyer.UserID))
+}
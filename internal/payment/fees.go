@@ -0,0 +1,131 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// feeScheduleKey is the metadata key of the fee schedule a payment was
+// charged under.
+const feeScheduleKey = "fee_schedule_id"
+
+// ErrInvalidFeeQuote is returned for fee quotes missing an amount or
+// currency.
+var ErrInvalidFeeQuote = errors.New("invalid fee quote request")
+
+// FeeScheduleSource returns the fee schedules in effect at a time.
+type FeeScheduleSource interface {
+	SchedulesAt(ctx context.Context, t time.Time) ([]domain.FeeSchedule, error)
+}
+
+// WithFeeSchedules prices payments by the fee schedule that matches them.
+// Payments no schedule matches, or all of them without a source, are
+// charged the sender's segment or plan rate.
+func (s *Service) WithFeeSchedules(source FeeScheduleSource) *Service {
+	s.feeSchedules = source
+	return s
+}
+
+// FeeQuoteRequest describes a payment the sender wants priced.
+type FeeQuoteRequest struct {
+	SenderID            uuid.UUID       `json:"-"`
+	Amount              decimal.Decimal `json:"amount"`
+	Currency            domain.Currency `json:"currency"`
+	DestinationCurrency domain.Currency `json:"destination_currency"`
+	Channel             string          `json:"channel"`
+}
+
+// QuoteFee shows what a payment would be charged now and how the fee is
+// made up, before the sender confirms it.
+func (s *Service) QuoteFee(ctx context.Context, req *FeeQuoteRequest) (*domain.FeeBreakdown, error) {
+	if !req.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidFeeQuote)
+	}
+	if req.Currency == "" {
+		return nil, fmt.Errorf("%w: currency is required", ErrInvalidFeeQuote)
+	}
+	sender, err := s.userRepo.FindByID(ctx, req.SenderID)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to load sender")
+	}
+	return s.quoteFee(ctx, sender, req.Amount, req.Currency, req.DestinationCurrency, req.Channel)
+}
+
+// quoteFee prices a payment from sender now, to the cent.
+func (s *Service) quoteFee(ctx context.Context, sender *domain.User, amount decimal.Decimal, from, to domain.Currency, channel string) (*domain.FeeBreakdown, error) {
+	now := time.Now().UTC()
+	schedules, err := s.feeSchedulesAt(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if f := domain.SelectFeeSchedule(schedules, feeQuery(sender, from, to, channel, now)); f != nil {
+		return f.Breakdown(amount, from), nil
+	}
+	rate, err := s.FeeRate(ctx, sender.ID)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to load fee rate")
+	}
+	b := domain.RateFeeBreakdown(amount, from, rate)
+	b.PercentageFee = b.PercentageFee.Round(2)
+	b.Fee = b.PercentageFee
+	b.TotalDebit = amount.Add(b.Fee)
+	return b, nil
+}
+
+// feeSchedulesAt returns the schedules in effect at t, or none without a
+// source.
+func (s *Service) feeSchedulesAt(ctx context.Context, t time.Time) ([]domain.FeeSchedule, error) {
+	if s.feeSchedules == nil {
+		return nil, nil
+	}
+	schedules, err := s.feeSchedules.SchedulesAt(ctx, t)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to load fee schedules")
+	}
+	return schedules, nil
+}
+
+// paymentFee prices a payment by the schedule matching q, or at the
+// sender's segment or plan rate when none does.
+func paymentFee(schedules []domain.FeeSchedule, q domain.FeeQuery, amount decimal.Decimal, currency domain.Currency, ent *domain.Entitlements, policy *domain.SegmentPolicy) *domain.FeeBreakdown {
+	if f := domain.SelectFeeSchedule(schedules, q); f != nil {
+		return f.Breakdown(amount, currency)
+	}
+	return domain.RateFeeBreakdown(amount, currency, paymentFeeRate(ent, policy))
+}
+
+// feeQuery describes a payment from sender for fee schedule matching. A
+// payment with no destination currency stays in its own.
+func feeQuery(sender *domain.User, from, to domain.Currency, channel string, at time.Time) domain.FeeQuery {
+	if to == "" {
+		to = from
+	}
+	q := domain.FeeQuery{SourceCurrency: from, DestinationCurrency: to, Channel: strings.TrimSpace(channel), At: at}
+	if sender != nil {
+		q.UserType, q.KYCLevel = sender.UserType, sender.KYCLevel
+	}
+	return q
+}
+
+// withFeeSchedule records the schedule a payment was charged under.
+// metadata is copied, not changed.
+func withFeeSchedule(metadata domain.Metadata, fee *domain.FeeBreakdown) domain.Metadata {
+	if fee.ScheduleID == nil {
+		return metadata
+	}
+	out := make(domain.Metadata, len(metadata)+1)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	out[feeScheduleKey] = fee.ScheduleID.String()
+	return out
+}
@@ -0,0 +1,76 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedSchedules serves the same fee schedules at any time.
+type fixedSchedules []domain.FeeSchedule
+
+func (f fixedSchedules) SchedulesAt(context.Context, time.Time) ([]domain.FeeSchedule, error) {
+	return f, nil
+}
+
+func TestQuoteFee(t *testing.T) {
+	ctx := context.Background()
+	senderID := uuid.New()
+	users := new(MockUserRepository)
+	users.On("FindByID", mock.Anything, senderID).
+		Return(&domain.User{ID: senderID, UserType: domain.UserTypeMerchant, KYCLevel: 2}, nil)
+	s := NewService(nil, nil, nil, nil, users, nil, nil, nil, logger.NewNop(), nil)
+
+	_, err := s.QuoteFee(ctx, &FeeQuoteRequest{SenderID: senderID, Currency: domain.MWK})
+	assert.ErrorIs(t, err, ErrInvalidFeeQuote)
+
+	// Without schedules the plan rate applies, to the cent
+	quote, err := s.QuoteFee(ctx, &FeeQuoteRequest{SenderID: senderID, Amount: decimal.RequireFromString("1234.56"), Currency: domain.MWK})
+	require.NoError(t, err)
+	assert.Nil(t, quote.ScheduleID)
+	assert.Equal(t, "0.015", quote.Percentage.String())
+	assert.Equal(t, "18.52", quote.Fee.String())
+	assert.Equal(t, "1253.08", quote.TotalDebit.String())
+
+	mwk, zar := domain.MWK, domain.ZAR
+	merchant := domain.UserTypeMerchant
+	maxFee := decimal.NewFromInt(2000)
+	corridor := domain.FeeSchedule{
+		ID: uuid.New(), Name: "Merchants MWK to ZAR", SourceCurrency: &mwk, DestinationCurrency: &zar, UserType: &merchant,
+		FlatFee: decimal.NewFromInt(250), Percentage: decimal.RequireFromString("0.005"), MaxFee: &maxFee,
+		EffectiveFrom: time.Now().Add(-time.Hour),
+	}
+	s.WithFeeSchedules(fixedSchedules{corridor})
+
+	quote, err = s.QuoteFee(ctx, &FeeQuoteRequest{SenderID: senderID, Amount: decimal.NewFromInt(100000), Currency: domain.MWK, DestinationCurrency: domain.ZAR})
+	require.NoError(t, err)
+	require.NotNil(t, quote.ScheduleID)
+	assert.Equal(t, corridor.ID, *quote.ScheduleID)
+	assert.Equal(t, "750", quote.Fee.String(), "250 flat + 0.5% of 100,000")
+	quote, err = s.QuoteFee(ctx, &FeeQuoteRequest{SenderID: senderID, Amount: decimal.NewFromInt(1000000), Currency: domain.MWK, DestinationCurrency: domain.ZAR})
+	require.NoError(t, err)
+	assert.Equal(t, "2000", quote.Fee.String())
+	assert.Equal(t, "max", quote.Capped)
+
+	// Payments within MWK match no schedule
+	quote, err = s.QuoteFee(ctx, &FeeQuoteRequest{SenderID: senderID, Amount: decimal.NewFromInt(100000), Currency: domain.MWK})
+	require.NoError(t, err)
+	assert.Nil(t, quote.ScheduleID)
+	assert.Equal(t, "1500", quote.Fee.String())
+
+	// The payment records the schedule it was charged under
+	fee := paymentFee(fixedSchedules{corridor}, feeQuery(&domain.User{UserType: domain.UserTypeMerchant}, domain.MWK, domain.ZAR, "api", time.Now()),
+		decimal.NewFromInt(100000), domain.MWK, nil, nil)
+	metadata := withFeeSchedule(domain.Metadata{"note": "x"}, fee)
+	assert.Equal(t, corridor.ID.String(), metadata[feeScheduleKey])
+	assert.Equal(t, "x", metadata["note"])
+}
@@ -0,0 +1,29 @@
+package payment
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+)
+
+// FreezeChecker refuses money movements an admin has frozen with
+// kyd/pkg/errors.ErrMovementFrozen. Satisfied by freeze.Service.
+type FreezeChecker interface {
+	Check(ctx context.Context, m domain.Movement) error
+}
+
+// WithFreezes refuses payments covered by an active money movement freeze
+// at initiation.
+func (s *Service) WithFreezes(f FreezeChecker) *Service {
+	s.freezes = f
+	return s
+}
+
+// checkFreeze refuses a payment between the sender's and receiver's
+// currencies while it is frozen.
+func (s *Service) checkFreeze(ctx context.Context, from, to domain.Currency) error {
+	if s.freezes == nil {
+		return nil
+	}
+	return s.freezes.Check(ctx, domain.Movement{Kind: domain.MovementPayment, From: from, To: to})
+}
@@ -0,0 +1,168 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultInitiationBudget is the p95 latency InitiatePayment is expected to
+// stay within; slower initiations are logged.
+const DefaultInitiationBudget = 300 * time.Millisecond
+
+// WithInitiationBudget sets the initiation latency budget. Zero disables the
+// slow-initiation warning.
+func (s *Service) WithInitiationBudget(budget time.Duration) *Service {
+	s.initiationBudget = budget
+	return s
+}
+
+// initiation holds the reads InitiatePayment's checks depend on. None of
+// them depends on another, so they are fetched concurrently instead of one
+// round trip at a time; the checks then run in their usual order on the
+// loaded values, so which check rejects a payment does not change.
+type initiation struct {
+	senderBlocked   bool
+	receiverBlocked bool
+	addressBlocked  bool
+	dailyTotal      decimal.Decimal
+	hourlyCount     int
+	parties         *domain.PaymentParties
+	controls        *domain.SpendingControls
+	entitlements    *domain.Entitlements
+	segments        *domain.SegmentPolicy
+	feeSchedules    []domain.FeeSchedule
+	// at is when the payment is priced.
+	at time.Time
+}
+
+// preloadInitiation performs the blocklist, limit and party lookups for req.
+// Each is a single short query, so a failure does not cancel the others.
+func (s *Service) preloadInitiation(ctx context.Context, req *InitiatePaymentRequest) (*initiation, error) {
+	pre := initiation{at: time.Now().UTC()}
+	var g errgroup.Group
+
+	blocklisted := func(value string, blocked *bool) func() error {
+		return func() error {
+			b, err := s.securityRepo.IsBlacklisted(ctx, value)
+			if err != nil {
+				s.logger.Error("Failed to check blocklist", map[string]interface{}{"error": err.Error()})
+				return errors.New("system error: unable to verify security status")
+			}
+			*blocked = b
+			return nil
+		}
+	}
+	g.Go(blocklisted(req.SenderID.String(), &pre.senderBlocked))
+	if req.ReceiverID != uuid.Nil {
+		g.Go(blocklisted(req.ReceiverID.String(), &pre.receiverBlocked))
+	}
+	if req.ReceiverWalletAddress != "" {
+		g.Go(blocklisted(req.ReceiverWalletAddress, &pre.addressBlocked))
+	}
+
+	g.Go(func() error {
+		total, err := s.repo.GetDailyTotal(ctx, req.SenderID, req.Currency)
+		if err != nil {
+			// Fail closed: an unknown daily total cannot be checked.
+			s.logger.Error("Failed to fetch daily total", map[string]interface{}{"error": err.Error()})
+			return pkgerrors.Wrap(err, "failed to verify daily limit")
+		}
+		pre.dailyTotal = total
+		return nil
+	})
+	g.Go(func() error {
+		count, err := s.repo.GetHourlyCount(ctx, req.SenderID)
+		if err != nil {
+			return pkgerrors.Wrap(err, "failed to check velocity")
+		}
+		pre.hourlyCount = count
+		return nil
+	})
+	g.Go(func() error {
+		receiverCurrency := req.DestinationCurrency
+		if receiverCurrency == "" {
+			receiverCurrency = req.Currency
+		}
+		parties, err := s.walletRepo.FindPaymentParties(ctx, domain.PaymentPartiesQuery{
+			SenderID:         req.SenderID,
+			Currency:         req.Currency,
+			ReceiverAddress:  req.ReceiverWalletAddress,
+			ReceiverID:       req.ReceiverID,
+			ReceiverCurrency: receiverCurrency,
+		})
+		if err != nil {
+			return pkgerrors.Wrap(err, "failed to load payment parties")
+		}
+		pre.parties = parties
+		return nil
+	})
+	if s.controls != nil {
+		g.Go(func() error {
+			// Read on every initiation, never cached, so a freeze applies to
+			// the very next payment. Fail closed like the daily total.
+			controls, err := s.controls.Get(ctx, req.SenderID)
+			if err != nil {
+				return pkgerrors.Wrap(err, "failed to load spending controls")
+			}
+			pre.controls = controls
+			return nil
+		})
+	}
+	if s.entitlements != nil {
+		g.Go(func() error {
+			ent, err := s.entitlements.Entitlements(ctx, req.SenderID)
+			if err != nil {
+				return pkgerrors.Wrap(err, "failed to load plan entitlements")
+			}
+			pre.entitlements = ent
+			return nil
+		})
+	}
+	if s.segments != nil {
+		g.Go(func() error {
+			policy, err := s.segments.PolicyFor(ctx, req.SenderID)
+			if err != nil {
+				return pkgerrors.Wrap(err, "failed to load segment policy")
+			}
+			pre.segments = policy
+			return nil
+		})
+	}
+
+	if s.feeSchedules != nil {
+		g.Go(func() error {
+			schedules, err := s.feeSchedulesAt(ctx, pre.at)
+			if err != nil {
+				return err
+			}
+			pre.feeSchedules = schedules
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return &pre, nil
+}
+
+// observeInitiation warns when an initiation took longer than the budget.
+func (s *Service) observeInitiation(started time.Time, req *InitiatePaymentRequest) {
+	elapsed := time.Since(started)
+	if s.initiationBudget <= 0 || elapsed <= s.initiationBudget {
+		return
+	}
+	s.logger.Warn("Payment initiation exceeded latency budget", map[string]interface{}{
+		"elapsed_ms": elapsed.Milliseconds(),
+		"budget_ms":  s.initiationBudget.Milliseconds(),
+		"sender_id":  req.SenderID,
+	})
+}
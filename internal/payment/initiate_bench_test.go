@@ -0,0 +1,75 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+)
+
+// benchStoreLatency is the simulated round trip of every repository and
+// provider call, roughly a same-region Postgres query.
+const benchStoreLatency = 2 * time.Millisecond
+
+// BenchmarkInitiatePayment measures the initiation warm path with every
+// store call delayed by benchStoreLatency, so the result tracks how many
+// sequential round trips a payment makes rather than CPU time.
+//
+//	go test ./internal/payment -run '^$' -bench InitiatePayment -benchtime 200x
+//
+// With 2ms per call, initiation went from ~24.0ms/op (eleven sequential
+// round trips) to ~8.8ms/op after the parties were loaded in one query and
+// the blocklist, limit and party reads were made concurrent.
+func BenchmarkInitiatePayment(b *testing.B) {
+	ctx := context.Background()
+	senderID, receiverID := uuid.New(), uuid.New()
+	address := "1234567890123456"
+	sender := &domain.User{ID: senderID, KYCStatus: domain.KYCStatusVerified, KYCLevel: 3}
+	senderWallet := &domain.Wallet{ID: uuid.New(), UserID: senderID, Currency: domain.MWK, AvailableBalance: decimal.NewFromInt(1_000_000), Status: domain.WalletStatusActive}
+	receiverWallet := &domain.Wallet{ID: uuid.New(), UserID: receiverID, Currency: domain.MWK, WalletAddress: &address, Status: domain.WalletStatusActive}
+
+	repo := new(MockRepository)
+	wallets := new(MockWalletRepository)
+	users := new(MockUserRepository)
+	forex := new(MockForexService)
+	ledger := new(MockLedgerService)
+	notifier := new(MockNotificationService)
+	security := new(MockSecurityRepository)
+	log := new(MockLogger)
+
+	security.On("IsBlacklisted", mock.Anything, mock.Anything).After(benchStoreLatency).Return(false, nil)
+	repo.On("GetDailyTotal", mock.Anything, senderID, domain.MWK).After(benchStoreLatency).Return(decimal.Zero, nil)
+	repo.On("GetHourlyCount", mock.Anything, senderID).After(benchStoreLatency).Return(0, nil)
+	wallets.On("FindPaymentParties", mock.Anything, mock.Anything).After(benchStoreLatency).
+		Return(&domain.PaymentParties{Sender: sender, SenderWallet: senderWallet, ReceiverWallet: receiverWallet}, nil)
+	forex.On("GetRate", mock.Anything, domain.MWK, domain.MWK).After(benchStoreLatency).Return(&domain.ExchangeRate{Rate: decimal.NewFromInt(1), SellRate: decimal.NewFromInt(1)}, nil)
+	repo.On("Create", mock.Anything, mock.Anything).After(benchStoreLatency).Return(nil)
+	repo.On("Update", mock.Anything, mock.Anything).After(benchStoreLatency).Return(nil)
+	ledger.On("PostTransaction", mock.Anything, mock.Anything).After(benchStoreLatency).Return(nil)
+	notifier.On("Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	security.On("LogSecurityEvent", mock.Anything, mock.Anything).Return(nil)
+	log.On("Info", mock.Anything, mock.Anything).Return()
+	log.On("Warn", mock.Anything, mock.Anything).Return()
+
+	service := NewService(repo, wallets, forex, ledger, users, notifier, new(MockAuditRepository), security, log, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := service.InitiatePayment(ctx, &InitiatePaymentRequest{
+			SenderID:              senderID,
+			ReceiverID:            receiverID,
+			ReceiverWalletAddress: address,
+			Amount:                decimal.NewFromInt(100),
+			Currency:              domain.MWK,
+			DestinationCurrency:   domain.MWK,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
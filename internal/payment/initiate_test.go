@@ -0,0 +1,62 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreloadInitiation(t *testing.T) {
+	repo := new(MockRepository)
+	wallets := new(MockWalletRepository)
+	security := new(MockSecurityRepository)
+	log := new(MockLogger)
+	service := NewService(repo, wallets, new(MockForexService), new(MockLedgerService), new(MockUserRepository), new(MockNotificationService), new(MockAuditRepository), security, log, nil)
+
+	ctx := context.Background()
+	req := &InitiatePaymentRequest{
+		SenderID:              uuid.New(),
+		ReceiverWalletAddress: "4539 1028 3475 6192",
+		Amount:                decimal.NewFromInt(100),
+		Currency:              domain.MWK,
+	}
+	parties := &domain.PaymentParties{SenderWallet: &domain.Wallet{ID: uuid.New()}}
+
+	security.On("IsBlacklisted", ctx, req.SenderID.String()).Return(false, nil)
+	security.On("IsBlacklisted", ctx, req.ReceiverWalletAddress).Return(true, nil)
+	repo.On("GetDailyTotal", ctx, req.SenderID, domain.MWK).Return(decimal.NewFromInt(500), nil)
+	repo.On("GetHourlyCount", ctx, req.SenderID).Return(2, nil)
+	wallets.On("FindPaymentParties", ctx, domain.PaymentPartiesQuery{
+		SenderID:         req.SenderID,
+		Currency:         domain.MWK,
+		ReceiverAddress:  req.ReceiverWalletAddress,
+		ReceiverCurrency: domain.MWK,
+	}).Return(parties, nil)
+
+	pre, err := service.preloadInitiation(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, pre.senderBlocked)
+	assert.False(t, pre.receiverBlocked)
+	assert.True(t, pre.addressBlocked)
+	assert.True(t, decimal.NewFromInt(500).Equal(pre.dailyTotal))
+	assert.Equal(t, 2, pre.hourlyCount)
+	assert.Equal(t, parties, pre.parties)
+	security.AssertNumberOfCalls(t, "IsBlacklisted", 2)
+
+	// A failed read fails the whole preload.
+	failing := new(MockRepository)
+	failing.On("GetDailyTotal", ctx, req.SenderID, domain.MWK).Return(decimal.Zero, errors.New("db down"))
+	failing.On("GetHourlyCount", ctx, req.SenderID).Return(0, nil)
+	log.On("Error", mock.Anything, mock.Anything).Return()
+	service.repo = failing
+	_, err = service.preloadInitiation(ctx, req)
+	assert.ErrorContains(t, err, "failed to verify daily limit")
+}
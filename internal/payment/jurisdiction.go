@@ -0,0 +1,49 @@
+package payment
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+)
+
+// JurisdictionChecker refuses money moving out of or into a restricted
+// jurisdiction with kyd/pkg/errors.ErrJurisdictionRestricted. Satisfied by
+// jurisdiction.Service.
+type JurisdictionChecker interface {
+	Check(ctx context.Context, f domain.JurisdictionFlow) error
+}
+
+// WithJurisdictions refuses payments from or to a restricted jurisdiction at
+// initiation.
+func (s *Service) WithJurisdictions(j JurisdictionChecker) *Service {
+	s.jurisdictions = j
+	return s
+}
+
+// checkJurisdictions refuses a payment leaving a country restricted
+// inbound, or reaching one restricted outbound. It leaves the sender's
+// country, where they say they are paying from and the country of the
+// sending currency, and reaches the receiver's country and that of the
+// receiving currency.
+func (s *Service) checkJurisdictions(ctx context.Context, req *InitiatePaymentRequest, parties *domain.PaymentParties, senderWallet, receiverWallet *domain.Wallet) error {
+	if s.jurisdictions == nil {
+		return nil
+	}
+	f := domain.CorridorFlow(senderWallet.Currency, receiverWallet.Currency)
+	f.Origins = append(f.Origins, req.Location)
+	if parties.Sender != nil {
+		f.Origins = append(f.Origins, parties.Sender.CountryCode)
+	}
+	if parties.ReceiverWallet != nil && parties.ReceiverWallet.ID == receiverWallet.ID {
+		f.Destinations = append(f.Destinations, parties.ReceiverCountry)
+	} else {
+		// Resolved outside the preload, by a derived wallet number
+		receiver, err := s.userRepo.FindByID(ctx, receiverWallet.UserID)
+		if err != nil {
+			return pkgerrors.Wrap(err, "failed to load receiver")
+		}
+		f.Destinations = append(f.Destinations, receiver.CountryCode)
+	}
+	return s.jurisdictions.Check(ctx, f)
+}
@@ -0,0 +1,55 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restrictedTo refuses money reaching its country.
+type restrictedTo struct {
+	country string
+	flows   []domain.JurisdictionFlow
+}
+
+func (r *restrictedTo) Check(ctx context.Context, f domain.JurisdictionFlow) error {
+	r.flows = append(r.flows, f)
+	for _, c := range f.Destinations {
+		if c == r.country {
+			return fmt.Errorf("%w: payments to %s are not permitted", pkgerrors.ErrJurisdictionRestricted, c)
+		}
+	}
+	return nil
+}
+
+func TestInitiatePayment_RefusesRestrictedJurisdiction(t *testing.T) {
+	ctx := context.Background()
+	w := newSagaWorld(domain.ZAR)
+	w.cutOffs = nil
+	w.wallets.parties.Sender.CountryCode = "MW"
+	w.wallets.parties.ReceiverCountry = "IR"
+	checker := &restrictedTo{country: "IR"}
+	s := w.service("", nil).WithJurisdictions(checker)
+
+	_, err := s.InitiatePayment(ctx, &InitiatePaymentRequest{
+		SenderID:              w.sender.UserID,
+		ReceiverWalletAddress: *w.receiver.WalletAddress,
+		Amount:                decimal.NewFromInt(100),
+		Currency:              domain.MWK,
+		DestinationCurrency:   domain.ZAR,
+		Location:              "GB",
+	})
+	assert.ErrorIs(t, err, pkgerrors.ErrJurisdictionRestricted)
+	require.Len(t, checker.flows, 1)
+	assert.ElementsMatch(t, []string{"MW", "GB", "MW"}, checker.flows[0].Origins, "the sending currency's, the stated and the sender's country")
+	assert.ElementsMatch(t, []string{"ZA", "IR"}, checker.flows[0].Destinations)
+	assert.Empty(t, w.txs.txs, "refused before anything is recorded")
+	assert.Equal(t, "1000", w.sender.AvailableBalance.String())
+}
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/risk"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// highValuePerHour is how many payments above the risk engine's high-value
+// threshold a user may send in an hour.
+const highValuePerHour = risk.HighValuePerHour
+
+// kycLimits returns the largest single payment and the rolling 24-hour total
+// a user at KYC level may send. Level 0 may not send at all.
+// Note: In a production environment, limits should be normalized to a base currency.
+// Current implementation assumes limits apply to the transaction currency directly.
+func kycLimits(level int) (perTransaction, daily decimal.Decimal) {
+	switch level {
+	case 1:
+		return decimal.NewFromInt(5000000), decimal.NewFromInt(10000000) // Tier 1: 5M limit (increased for testing), 10M daily
+	case 2:
+		return decimal.NewFromInt(10000000), decimal.NewFromInt(50000000) // Tier 2: 10M limit, 50M daily
+	case 3:
+		return decimal.NewFromInt(100000000), decimal.NewFromInt(500000000) // Tier 3: 100M limit, 500M daily
+	default:
+		return decimal.Zero, decimal.Zero // Tier 0: No sending
+	}
+}
+
+// DailyLimit is the rolling 24-hour sending allowance in one currency.
+type DailyLimit struct {
+	Currency  domain.Currency `json:"currency"`
+	Limit     decimal.Decimal `json:"limit"`
+	Used      decimal.Decimal `json:"used"`
+	Remaining decimal.Decimal `json:"remaining"`
+}
+
+// CountLimit is an allowance counted in payments rather than amounts.
+type CountLimit struct {
+	Limit     int `json:"limit"`
+	Used      int `json:"used"`
+	Remaining int `json:"remaining"`
+}
+
+// HighValueLimit caps how many payments above Threshold may be sent per hour.
+type HighValueLimit struct {
+	Threshold decimal.Decimal `json:"threshold"`
+	PerHour   int             `json:"per_hour"`
+}
+
+// TransactionLimits are the sending limits that currently apply to a user,
+// as InitiatePayment enforces them.
+type TransactionLimits struct {
+	KYCLevel  int             `json:"kyc_level"`
+	Plan      domain.PlanCode `json:"plan,omitempty"`
+	CanSend   bool            `json:"can_send"`
+	PerTx     decimal.Decimal `json:"per_transaction"`
+	Daily     []DailyLimit    `json:"daily"`
+	Hourly    *CountLimit     `json:"hourly,omitempty"`
+	HighValue *HighValueLimit `json:"high_value,omitempty"`
+}
+
+// GetTransactionLimits reports the user's limits and how much of them the
+// last 24 hours (daily) and hour (count) have used, per wallet currency.
+func (s *Service) GetTransactionLimits(ctx context.Context, userID uuid.UUID) (*TransactionLimits, error) {
+	return s.transactionLimits(ctx, userID, limitUsage{
+		daily:  func(c domain.Currency) (decimal.Decimal, error) { return s.repo.GetDailyTotal(ctx, userID, c) },
+		hourly: func() (int, error) { return s.repo.GetHourlyCount(ctx, userID) },
+	})
+}
+
+// limitUsage reads how much of the daily and hourly limits has been used,
+// and keeps wallets for which the daily limit is reported.
+type limitUsage struct {
+	daily  func(domain.Currency) (decimal.Decimal, error)
+	hourly func() (int, error)
+	wallet func(*domain.Wallet) bool
+}
+
+func (s *Service) transactionLimits(ctx context.Context, userID uuid.UUID, usage limitUsage) (*TransactionLimits, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to fetch user")
+	}
+	wallets, err := s.walletRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to fetch wallets")
+	}
+
+	var ent *domain.Entitlements
+	if s.entitlements != nil {
+		if ent, err = s.entitlements.Entitlements(ctx, userID); err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to fetch plan entitlements")
+		}
+	}
+	perTx, daily := planLimits(user.KYCLevel, ent)
+	limits := &TransactionLimits{
+		KYCLevel: user.KYCLevel,
+		CanSend:  user.KYCLevel > 0 && user.KYCStatus == domain.KYCStatusVerified,
+		PerTx:    perTx,
+		Daily:    []DailyLimit{},
+	}
+	if ent != nil {
+		limits.Plan = ent.Plan
+	}
+	if s.riskEngine != nil {
+		// The risk engine's platform-wide cap applies on top of the KYC tier.
+		if riskDaily := decimal.NewFromInt(s.riskEngine.GetConfig().MaxDailyLimit); riskDaily.LessThan(daily) {
+			daily = riskDaily
+		}
+	}
+
+	for _, w := range wallets {
+		if usage.wallet != nil && !usage.wallet(w) {
+			continue
+		}
+		used, err := usage.daily(w.Currency)
+		if err != nil {
+			return nil, err
+		}
+		remaining := daily.Sub(used)
+		if remaining.IsNegative() {
+			remaining = decimal.Zero
+		}
+		limits.Daily = append(limits.Daily, DailyLimit{Currency: w.Currency, Limit: daily, Used: used, Remaining: remaining})
+	}
+
+	if s.riskEngine != nil {
+		cfg := s.riskEngine.GetConfig()
+		hourly, err := usage.hourly()
+		if err != nil {
+			return nil, err
+		}
+		remaining := cfg.MaxVelocityPerHour - hourly
+		if remaining < 0 {
+			remaining = 0
+		}
+		limits.Hourly = &CountLimit{Limit: cfg.MaxVelocityPerHour, Used: hourly, Remaining: remaining}
+		limits.HighValue = &HighValueLimit{Threshold: decimal.NewFromInt(cfg.HighValueThreshold), PerHour: highValuePerHour}
+	}
+	return limits, nil
+}
@@ -0,0 +1,256 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxNoteLength = 1000
+	maxNoteTags   = 10
+	maxTagLength  = 32
+)
+
+var (
+	// ErrInvalidNote is returned for a note or tags outside the limits.
+	ErrInvalidNote = errors.New("invalid note")
+	// ErrNotTransactionParty is returned when a customer touches the notes
+	// of a transaction they neither sent nor received.
+	ErrNotTransactionParty = errors.New("not a party to this transaction")
+	// ErrNotesDisabled is returned when no note repository is configured.
+	ErrNotesDisabled = errors.New("transaction notes are not enabled")
+)
+
+// NoteRepository persists transaction notes.
+type NoteRepository interface {
+	// UpsertPrivate creates or replaces the author's private note on a
+	// transaction, keeping the ID and creation time of an existing one.
+	UpsertPrivate(ctx context.Context, n *domain.TransactionNote) error
+	DeletePrivate(ctx context.Context, txID, authorID uuid.UUID) error
+	// PrivateNotes returns the author's notes on the given transactions,
+	// keyed by transaction ID.
+	PrivateNotes(ctx context.Context, authorID uuid.UUID, txIDs []uuid.UUID) (map[uuid.UUID]*domain.TransactionNote, error)
+	// SearchPrivate returns, newest transaction first, the IDs of
+	// transactions whose private note by author contains query and carries
+	// tag; empty arguments match anything.
+	SearchPrivate(ctx context.Context, authorID uuid.UUID, query, tag string, limit, offset int) ([]uuid.UUID, int, error)
+	AddInternal(ctx context.Context, n *domain.TransactionNote) error
+	ListInternal(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionNote, error)
+	// SearchInternal returns, newest transaction first, the IDs of
+	// transactions with an internal note containing query.
+	SearchInternal(ctx context.Context, query string, limit, offset int) ([]uuid.UUID, int, error)
+}
+
+// WithNotes lets customers annotate their transactions and support add
+// internal notes.
+func (s *Service) WithNotes(repo NoteRepository) *Service {
+	s.notes = repo
+	return s
+}
+
+// SetUserNote replaces the customer's private note and tags on one of their
+// transactions. An empty note with no tags removes it, and nil is returned.
+func (s *Service) SetUserNote(ctx context.Context, txID, userID uuid.UUID, body string, tags []string) (*domain.TransactionNote, error) {
+	if s.notes == nil {
+		return nil, ErrNotesDisabled
+	}
+	if _, err := s.partyTransaction(ctx, txID, userID); err != nil {
+		return nil, err
+	}
+	body = strings.TrimSpace(body)
+	if utf8.RuneCountInString(body) > maxNoteLength {
+		return nil, fmt.Errorf("%w: note must be at most %d characters", ErrInvalidNote, maxNoteLength)
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if body == "" && len(tags) == 0 {
+		return nil, s.notes.DeletePrivate(ctx, txID, userID)
+	}
+
+	now := time.Now().UTC()
+	n := &domain.TransactionNote{
+		ID:            uuid.New(),
+		TransactionID: txID,
+		AuthorID:      userID,
+		Visibility:    domain.TransactionNotePrivate,
+		Body:          body,
+		Tags:          tags,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.notes.UpsertPrivate(ctx, n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// GetUserNote returns the customer's private note on a transaction, or nil.
+func (s *Service) GetUserNote(ctx context.Context, txID, userID uuid.UUID) (*domain.TransactionNote, error) {
+	if s.notes == nil {
+		return nil, ErrNotesDisabled
+	}
+	if _, err := s.partyTransaction(ctx, txID, userID); err != nil {
+		return nil, err
+	}
+	notes, err := s.notes.PrivateNotes(ctx, userID, []uuid.UUID{txID})
+	if err != nil {
+		return nil, err
+	}
+	return notes[txID], nil
+}
+
+// AddInternalNote appends a support note to a transaction.
+func (s *Service) AddInternalNote(ctx context.Context, txID, authorID uuid.UUID, body string) (*domain.TransactionNote, error) {
+	if s.notes == nil {
+		return nil, ErrNotesDisabled
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: note is required", ErrInvalidNote)
+	}
+	if utf8.RuneCountInString(body) > maxNoteLength {
+		return nil, fmt.Errorf("%w: note must be at most %d characters", ErrInvalidNote, maxNoteLength)
+	}
+	if _, err := s.repo.FindByID(ctx, txID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	n := &domain.TransactionNote{
+		ID:            uuid.New(),
+		TransactionID: txID,
+		AuthorID:      authorID,
+		Visibility:    domain.TransactionNoteInternal,
+		Tags:          []string{},
+		Body:          body,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.notes.AddInternal(ctx, n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// ListInternalNotes returns a transaction's support notes, oldest first.
+func (s *Service) ListInternalNotes(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionNote, error) {
+	if s.notes == nil {
+		return []*domain.TransactionNote{}, nil
+	}
+	return s.notes.ListInternal(ctx, txID)
+}
+
+// SearchUserTransactions returns the customer's transactions whose private
+// note contains query and that carry tag, newest first, with their notes.
+func (s *Service) SearchUserTransactions(ctx context.Context, userID uuid.UUID, query, tag string, limit, offset int) ([]*TransactionDetail, int, error) {
+	if s.notes == nil {
+		return nil, 0, ErrNotesDisabled
+	}
+	ids, total, err := s.notes.SearchPrivate(ctx, userID, strings.TrimSpace(query), normalizeTag(tag), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	txs, err := s.findTransactions(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	details := s.userTransactionDetails(ctx, txs)
+	s.attachUserNotes(ctx, userID, details)
+	return details, total, nil
+}
+
+// SearchTransactionsByInternalNote returns the transactions with a support
+// note containing query, newest first.
+func (s *Service) SearchTransactionsByInternalNote(ctx context.Context, query string, limit, offset int) ([]*TransactionDetail, int, error) {
+	if s.notes == nil {
+		return nil, 0, ErrNotesDisabled
+	}
+	ids, total, err := s.notes.SearchInternal(ctx, strings.TrimSpace(query), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	txs, err := s.findTransactions(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.userTransactionDetails(ctx, txs), total, nil
+}
+
+// attachUserNotes sets each detail's Note to the customer's private note.
+// Notes are an annotation on the list; failing to load them is logged and
+// the list is returned without them.
+func (s *Service) attachUserNotes(ctx context.Context, userID uuid.UUID, details []*TransactionDetail) {
+	if s.notes == nil || len(details) == 0 {
+		return
+	}
+	ids := make([]uuid.UUID, len(details))
+	for i, d := range details {
+		ids[i] = d.ID
+	}
+	notes, err := s.notes.PrivateNotes(ctx, userID, ids)
+	if err != nil {
+		s.logger.Warn("Failed to load transaction notes", map[string]interface{}{"user_id": userID, "error": err.Error()})
+		return
+	}
+	for _, d := range details {
+		d.Note = notes[d.ID]
+	}
+}
+
+func (s *Service) partyTransaction(ctx context.Context, txID, userID uuid.UUID) (*domain.Transaction, error) {
+	tx, err := s.repo.FindByID(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if tx.SenderID != userID && tx.ReceiverID != userID {
+		return nil, ErrNotTransactionParty
+	}
+	return tx, nil
+}
+
+func (s *Service) findTransactions(ctx context.Context, ids []uuid.UUID) ([]*domain.Transaction, error) {
+	txs := make([]*domain.Transaction, 0, len(ids))
+	for _, id := range ids {
+		tx, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// normalizeTags lower-cases, trims and de-duplicates tags, keeping their
+// order.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = normalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if utf8.RuneCountInString(t) > maxTagLength {
+			return nil, fmt.Errorf("%w: tags must be at most %d characters", ErrInvalidNote, maxTagLength)
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	if len(out) > maxNoteTags {
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidNote, maxNoteTags)
+	}
+	return out, nil
+}
+
+func normalizeTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(t), "#")))
+}
@@ -0,0 +1,242 @@
+package payment
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memNotes struct {
+	notes []*domain.TransactionNote
+	txs   *memTransactions
+}
+
+func (m *memNotes) UpsertPrivate(ctx context.Context, n *domain.TransactionNote) error {
+	for _, old := range m.notes {
+		if old.Visibility == domain.TransactionNotePrivate && old.TransactionID == n.TransactionID && old.AuthorID == n.AuthorID {
+			n.ID, n.CreatedAt = old.ID, old.CreatedAt
+			*old = *n
+			return nil
+		}
+	}
+	cp := *n
+	m.notes = append(m.notes, &cp)
+	return nil
+}
+
+func (m *memNotes) DeletePrivate(ctx context.Context, txID, authorID uuid.UUID) error {
+	kept := m.notes[:0]
+	for _, n := range m.notes {
+		if !(n.Visibility == domain.TransactionNotePrivate && n.TransactionID == txID && n.AuthorID == authorID) {
+			kept = append(kept, n)
+		}
+	}
+	m.notes = kept
+	return nil
+}
+
+func (m *memNotes) PrivateNotes(ctx context.Context, authorID uuid.UUID, txIDs []uuid.UUID) (map[uuid.UUID]*domain.TransactionNote, error) {
+	out := map[uuid.UUID]*domain.TransactionNote{}
+	for _, n := range m.notes {
+		if n.Visibility == domain.TransactionNotePrivate && n.AuthorID == authorID {
+			out[n.TransactionID] = n
+		}
+	}
+	return out, nil
+}
+
+func (m *memNotes) SearchPrivate(ctx context.Context, authorID uuid.UUID, query, tag string, limit, offset int) ([]uuid.UUID, int, error) {
+	return m.search(func(n *domain.TransactionNote) bool {
+		if n.Visibility != domain.TransactionNotePrivate || n.AuthorID != authorID {
+			return false
+		}
+		if !strings.Contains(strings.ToLower(n.Body), strings.ToLower(query)) {
+			return false
+		}
+		if tag == "" {
+			return true
+		}
+		for _, t := range n.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (m *memNotes) AddInternal(ctx context.Context, n *domain.TransactionNote) error {
+	cp := *n
+	m.notes = append(m.notes, &cp)
+	return nil
+}
+
+func (m *memNotes) ListInternal(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionNote, error) {
+	out := []*domain.TransactionNote{}
+	for _, n := range m.notes {
+		if n.Visibility == domain.TransactionNoteInternal && n.TransactionID == txID {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func (m *memNotes) SearchInternal(ctx context.Context, query string, limit, offset int) ([]uuid.UUID, int, error) {
+	return m.search(func(n *domain.TransactionNote) bool {
+		return n.Visibility == domain.TransactionNoteInternal && strings.Contains(strings.ToLower(n.Body), strings.ToLower(query))
+	})
+}
+
+func (m *memNotes) search(match func(*domain.TransactionNote) bool) ([]uuid.UUID, int, error) {
+	seen := map[uuid.UUID]bool{}
+	var ids []uuid.UUID
+	for _, n := range m.notes {
+		if match(n) && !seen[n.TransactionID] {
+			seen[n.TransactionID] = true
+			ids = append(ids, n.TransactionID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return m.txs.txs[ids[i]].CreatedAt.After(m.txs.txs[ids[j]].CreatedAt)
+	})
+	return ids, len(ids), nil
+}
+
+type noUsers struct {
+	UserRepository
+}
+
+func (noUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	return nil, pkgerrors.ErrUserNotFound
+}
+
+func newNotesService(t *testing.T) (*Service, *memTransactions, []uuid.UUID) {
+	txs := &memTransactions{txs: map[uuid.UUID]domain.Transaction{}}
+	alice, bob := uuid.New(), uuid.New()
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		tx := domain.Transaction{
+			ID:         uuid.New(),
+			SenderID:   alice,
+			ReceiverID: bob,
+			Status:     domain.TransactionStatusCompleted,
+			CreatedAt:  time.Now().Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, txs.Create(context.Background(), &tx))
+		ids = append(ids, tx.ID)
+	}
+	s := NewService(txs, nil, nil, nil, noUsers{}, nil, nil, nil, logger.NewNop(), nil).
+		WithNotes(&memNotes{txs: txs})
+	return s, txs, []uuid.UUID{alice, bob, ids[0], ids[1], ids[2]}
+}
+
+func TestSetUserNote(t *testing.T) {
+	ctx := context.Background()
+	s, _, ids := newNotesService(t)
+	alice, bob, tx := ids[0], ids[1], ids[2]
+
+	n, err := s.SetUserNote(ctx, tx, alice, "  Rent for March ", []string{"#Rent", "home", "rent", " "})
+	require.NoError(t, err)
+	assert.Equal(t, "Rent for March", n.Body)
+	assert.Equal(t, []string{"rent", "home"}, []string(n.Tags))
+
+	updated, err := s.SetUserNote(ctx, tx, alice, "Rent for April", nil)
+	require.NoError(t, err)
+	assert.Equal(t, n.ID, updated.ID, "a party has one note per transaction")
+
+	got, err := s.GetUserNote(ctx, tx, bob)
+	require.NoError(t, err)
+	assert.Nil(t, got, "notes are private to their author")
+
+	removed, err := s.SetUserNote(ctx, tx, alice, "", nil)
+	require.NoError(t, err)
+	assert.Nil(t, removed)
+	got, err = s.GetUserNote(ctx, tx, alice)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestSetUserNote_Rejects(t *testing.T) {
+	ctx := context.Background()
+	s, _, ids := newNotesService(t)
+	alice, tx := ids[0], ids[2]
+
+	_, err := s.SetUserNote(ctx, tx, uuid.New(), "mine", nil)
+	assert.ErrorIs(t, err, ErrNotTransactionParty)
+
+	_, err = s.SetUserNote(ctx, tx, alice, strings.Repeat("x", maxNoteLength+1), nil)
+	assert.ErrorIs(t, err, ErrInvalidNote)
+
+	tags := make([]string, maxNoteTags+1)
+	for i := range tags {
+		tags[i] = uuid.NewString()[:8]
+	}
+	_, err = s.SetUserNote(ctx, tx, alice, "", tags)
+	assert.ErrorIs(t, err, ErrInvalidNote)
+}
+
+func TestSearchUserTransactions(t *testing.T) {
+	ctx := context.Background()
+	s, _, ids := newNotesService(t)
+	alice, bob, first, second, third := ids[0], ids[1], ids[2], ids[3], ids[4]
+
+	_, err := s.SetUserNote(ctx, first, alice, "School fees", []string{"family"})
+	require.NoError(t, err)
+	_, err = s.SetUserNote(ctx, third, alice, "Groceries", []string{"family"})
+	require.NoError(t, err)
+	_, err = s.SetUserNote(ctx, second, bob, "school fees received", nil)
+	require.NoError(t, err)
+
+	txs, total, err := s.SearchUserTransactions(ctx, alice, "", "#Family", 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	assert.Equal(t, third, txs[0].ID, "newest first")
+	assert.Equal(t, "Groceries", txs[0].Note.Body)
+
+	txs, _, err = s.SearchUserTransactions(ctx, alice, "school", "", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, first, txs[0].ID, "another party's note does not match")
+}
+
+func TestInternalNotes(t *testing.T) {
+	ctx := context.Background()
+	s, _, ids := newNotesService(t)
+	alice, tx := ids[0], ids[3]
+	admin := uuid.New()
+
+	_, err := s.AddInternalNote(ctx, tx, admin, " ")
+	assert.ErrorIs(t, err, ErrInvalidNote)
+	_, err = s.AddInternalNote(ctx, uuid.New(), admin, "unknown")
+	assert.ErrorIs(t, err, pkgerrors.ErrTransactionNotFound)
+
+	_, err = s.AddInternalNote(ctx, tx, admin, "Customer called about a delay")
+	require.NoError(t, err)
+	notes, err := s.ListInternalNotes(ctx, tx)
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	assert.Equal(t, admin, notes[0].AuthorID)
+
+	found, total, err := s.SearchTransactionsByInternalNote(ctx, "DELAY", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, tx, found[0].ID)
+
+	// Internal notes never reach the customer.
+	note, err := s.GetUserNote(ctx, tx, alice)
+	require.NoError(t, err)
+	assert.Nil(t, note)
+	mine, _, err := s.SearchUserTransactions(ctx, alice, "delay", "", 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, mine)
+}
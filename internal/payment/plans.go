@@ -0,0 +1,55 @@
+package payment
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// standardFeeRate is charged when no plan entitlements are configured.
+var standardFeeRate = decimal.NewFromFloat(0.015)
+
+// EntitlementChecker reports what a user's plan allows.
+type EntitlementChecker interface {
+	Entitlements(ctx context.Context, userID uuid.UUID) (*domain.Entitlements, error)
+}
+
+// WithEntitlements applies the sender's plan to payment fees, limits and
+// settlement lane.
+func (s *Service) WithEntitlements(checker EntitlementChecker) *Service {
+	s.entitlements = checker
+	return s
+}
+
+// planLimits scales the KYC tier's limits by the plan's multiplier.
+func planLimits(kycLevel int, ent *domain.Entitlements) (perTransaction, daily decimal.Decimal) {
+	perTransaction, daily = kycLimits(kycLevel)
+	if ent == nil || !ent.LimitMultiplier.IsPositive() {
+		return perTransaction, daily
+	}
+	return perTransaction.Mul(ent.LimitMultiplier), daily.Mul(ent.LimitMultiplier)
+}
+
+func feeRate(ent *domain.Entitlements) decimal.Decimal {
+	if ent == nil {
+		return standardFeeRate
+	}
+	return ent.FeeRate
+}
+
+// withSettlementLane marks payments from plans with priority settlement so
+// that settlement batches them separately. metadata is copied, not changed.
+func withSettlementLane(metadata map[string]interface{}, ent *domain.Entitlements) domain.Metadata {
+	if ent == nil || !ent.PrioritySettlement {
+		return metadata
+	}
+	out := make(domain.Metadata, len(metadata)+1)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	out[domain.SettlementLaneKey] = domain.SettlementLanePriority
+	return out
+}
@@ -0,0 +1,461 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// memWallets is an in-memory WalletRepository with the same guards as the
+// postgres one: no update may take available or reserved below zero.
+type memWallets struct {
+	mu      sync.Mutex
+	wallets map[uuid.UUID]*domain.Wallet
+}
+
+func (m *memWallets) get(id uuid.UUID) (*domain.Wallet, error) {
+	w, ok := m.wallets[id]
+	if !ok {
+		return nil, pkgerrors.ErrWalletNotFound
+	}
+	return w, nil
+}
+
+func (m *memWallets) FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (m *memWallets) FindByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*domain.Wallet
+	for _, w := range m.wallets {
+		if w.UserID == userID {
+			cp := *w
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memWallets) FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error) {
+	ws, _ := m.FindByUserID(ctx, userID)
+	for _, w := range ws {
+		if w.Currency == currency {
+			return w, nil
+		}
+	}
+	return nil, pkgerrors.ErrWalletNotFound
+}
+
+func (m *memWallets) FindByAddress(ctx context.Context, address string) (*domain.Wallet, error) {
+	return nil, pkgerrors.ErrWalletNotFound
+}
+
+func (m *memWallets) FindPaymentParties(ctx context.Context, q domain.PaymentPartiesQuery) (*domain.PaymentParties, error) {
+	return nil, errors.New("not supported")
+}
+
+func (m *memWallets) update(id uuid.UUID, available, reserved, ledgerBal decimal.Decimal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	a, r := w.AvailableBalance.Add(available), w.ReservedBalance.Add(reserved)
+	if a.IsNegative() || r.IsNegative() {
+		return pkgerrors.ErrInsufficientBalance
+	}
+	w.AvailableBalance, w.ReservedBalance, w.LedgerBalance = a, r, w.LedgerBalance.Add(ledgerBal)
+	return nil
+}
+
+func (m *memWallets) DebitWallet(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	return m.update(id, amount.Neg(), decimal.Zero, amount.Neg())
+}
+
+func (m *memWallets) CreditWallet(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	return m.update(id, amount, decimal.Zero, amount)
+}
+
+func (m *memWallets) ReserveFunds(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	return m.update(id, amount.Neg(), amount, decimal.Zero)
+}
+
+func (m *memWallets) ReleaseReservedFunds(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	return m.update(id, amount, amount.Neg(), decimal.Zero)
+}
+
+func (m *memWallets) DebitReservedFunds(ctx context.Context, id uuid.UUID, amount decimal.Decimal) error {
+	return m.update(id, decimal.Zero, amount.Neg(), amount.Neg())
+}
+
+// memLedger posts to memWallets atomically, once per idempotency key, and
+// refuses postings the debit wallet cannot cover.
+type memLedger struct {
+	wallets *memWallets
+	posted  map[string]bool
+}
+
+func (l *memLedger) PostTransaction(ctx context.Context, p *ledger.LedgerPosting) error {
+	if p.IdempotencyKey != "" && l.posted[p.IdempotencyKey] {
+		return nil
+	}
+	w := l.wallets
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	debit, err := w.get(p.DebitWalletID)
+	if err != nil {
+		return err
+	}
+	credit, err := w.get(p.CreditWalletID)
+	if err != nil {
+		return err
+	}
+	if debit.AvailableBalance.LessThan(p.DebitAmount) {
+		return pkgerrors.ErrInsufficientBalance
+	}
+	var fee *domain.Wallet
+	if p.FeeWalletID != nil && p.FeeAmount.IsPositive() {
+		if fee, err = w.get(*p.FeeWalletID); err != nil {
+			return err
+		}
+	}
+	debit.AvailableBalance = debit.AvailableBalance.Sub(p.DebitAmount)
+	debit.LedgerBalance = debit.LedgerBalance.Sub(p.DebitAmount)
+	credit.AvailableBalance = credit.AvailableBalance.Add(p.CreditAmount)
+	credit.LedgerBalance = credit.LedgerBalance.Add(p.CreditAmount)
+	if fee != nil {
+		fee.AvailableBalance = fee.AvailableBalance.Add(p.FeeAmount)
+		fee.LedgerBalance = fee.LedgerBalance.Add(p.FeeAmount)
+	}
+	if p.IdempotencyKey != "" {
+		l.posted[p.IdempotencyKey] = true
+	}
+	return nil
+}
+
+// HoldEscrow, ReleaseEscrow and RefundEscrow move escrowed funds in
+// memWallets once per idempotency key, like the ledger's escrow postings.
+func (l *memLedger) HoldEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error) {
+	return l.escrow(p, func() error {
+		return l.wallets.update(p.SenderWalletID, p.Amount.Neg(), p.Amount, decimal.Zero)
+	})
+}
+
+func (l *memLedger) ReleaseEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error) {
+	return l.escrow(p, func() error {
+		if _, err := l.wallets.FindByID(ctx, p.ReceiverWalletID); err != nil {
+			return err
+		}
+		if err := l.wallets.update(p.SenderWalletID, decimal.Zero, p.Amount.Neg(), p.Amount.Neg()); err != nil {
+			return err
+		}
+		return l.wallets.update(p.ReceiverWalletID, p.Amount, decimal.Zero, p.Amount)
+	})
+}
+
+func (l *memLedger) RefundEscrow(ctx context.Context, p *ledger.EscrowPosting) (*ledger.PostingReceipt, error) {
+	return l.escrow(p, func() error {
+		return l.wallets.update(p.SenderWalletID, p.Amount, p.Amount.Neg(), decimal.Zero)
+	})
+}
+
+func (l *memLedger) escrow(p *ledger.EscrowPosting, move func() error) (*ledger.PostingReceipt, error) {
+	receipt := &ledger.PostingReceipt{IdempotencyKey: p.IdempotencyKey, TransactionID: p.TransactionID}
+	if l.posted[p.IdempotencyKey] {
+		receipt.Replayed = true
+		return receipt, nil
+	}
+	if err := move(); err != nil {
+		return nil, err
+	}
+	l.posted[p.IdempotencyKey] = true
+	return receipt, nil
+}
+
+// memTransactions stores transactions by ID; the rest of Repository is not
+// used by the flows under test.
+type memTransactions struct {
+	Repository
+	txs map[uuid.UUID]domain.Transaction
+}
+
+func (m *memTransactions) Create(ctx context.Context, tx *domain.Transaction) error {
+	m.txs[tx.ID] = *tx
+	return nil
+}
+
+func (m *memTransactions) Update(ctx context.Context, tx *domain.Transaction) error {
+	m.txs[tx.ID] = *tx
+	return nil
+}
+
+func (m *memTransactions) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	tx, ok := m.txs[id]
+	if !ok {
+		return nil, pkgerrors.ErrTransactionNotFound
+	}
+	return &tx, nil
+}
+
+func (m *memTransactions) ListEscrows(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, int, error) {
+	return nil, 0, nil
+}
+
+func (m *memTransactions) FindExpiredEscrows(ctx context.Context, now time.Time, limit int) ([]*domain.Transaction, error) {
+	return nil, nil
+}
+
+func (m *memTransactions) TransitionEscrow(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error) {
+	stored, ok := m.txs[tx.ID]
+	if !ok || stored.Status != from {
+		return false, nil
+	}
+	stored.Status, stored.StatusReason = tx.Status, tx.StatusReason
+	m.txs[tx.ID] = stored
+	return true, nil
+}
+
+// Operations of a generated script.
+const (
+	opPay = iota
+	opEscrow
+	opRelease
+	opRefund
+	opReverse
+	opCount
+)
+
+type scriptOp struct {
+	Kind     int
+	From, To int
+	Amount   int64 // cents
+	Fee      int64 // cents
+	Pick     int   // which earlier payment or escrow to act on
+}
+
+// script is a generated sequence of payments, escrows, releases, refunds and
+// reversals between a few users.
+type script struct {
+	Users   int
+	Opening []int64 // cents per user
+	Ops     []scriptOp
+}
+
+func (script) Generate(r *rand.Rand, size int) reflect.Value {
+	sc := script{Users: 2 + r.Intn(3)}
+	for i := 0; i < sc.Users; i++ {
+		sc.Opening = append(sc.Opening, r.Int63n(500000))
+	}
+	for i := 0; i < 1+r.Intn(2*size+1); i++ {
+		from := r.Intn(sc.Users)
+		sc.Ops = append(sc.Ops, scriptOp{
+			Kind:   r.Intn(opCount),
+			From:   from,
+			To:     (from + 1 + r.Intn(sc.Users-1)) % sc.Users,
+			Amount: 1 + r.Int63n(200000),
+			Fee:    r.Int63n(300),
+			Pick:   r.Intn(1 << 16),
+		})
+	}
+	return reflect.ValueOf(sc)
+}
+
+// world is the service under test wired to in-memory state.
+type world struct {
+	s        *Service
+	wallets  *memWallets
+	txs      *memTransactions
+	users    []uuid.UUID
+	walletOf map[uuid.UUID]uuid.UUID
+	opening  decimal.Decimal
+	payments []uuid.UUID
+	escrows  []uuid.UUID
+}
+
+func newWorld(sc script) *world {
+	w := &world{
+		wallets:  &memWallets{wallets: make(map[uuid.UUID]*domain.Wallet)},
+		txs:      &memTransactions{txs: make(map[uuid.UUID]domain.Transaction)},
+		walletOf: make(map[uuid.UUID]uuid.UUID),
+		opening:  decimal.Zero,
+	}
+	open := func(user uuid.UUID, cents int64) {
+		bal := decimal.New(cents, -2)
+		wallet := &domain.Wallet{ID: uuid.New(), UserID: user, Currency: "MWK", AvailableBalance: bal, LedgerBalance: bal, ReservedBalance: decimal.Zero}
+		w.wallets.wallets[wallet.ID] = wallet
+		w.walletOf[user] = wallet.ID
+		w.opening = w.opening.Add(bal)
+	}
+	for i := 0; i < sc.Users; i++ {
+		u := uuid.New()
+		w.users = append(w.users, u)
+		open(u, sc.Opening[i])
+	}
+	treasury := uuid.New()
+	open(treasury, 0)
+
+	l := &memLedger{wallets: w.wallets, posted: make(map[string]bool)}
+	w.s = NewService(w.txs, w.wallets, nil, l, nil, nil, nil, nil, logger.NewNop(), nil).WithEscrows(w.txs, l)
+	w.s.feeCollectorUserID = &treasury
+	return w
+}
+
+func (w *world) snapshot() map[uuid.UUID]domain.Wallet {
+	w.wallets.mu.Lock()
+	defer w.wallets.mu.Unlock()
+	out := make(map[uuid.UUID]domain.Wallet, len(w.wallets.wallets))
+	for id, wl := range w.wallets.wallets {
+		out[id] = *wl
+	}
+	return out
+}
+
+func pick(ids []uuid.UUID, n int) (uuid.UUID, bool) {
+	if len(ids) == 0 {
+		return uuid.Nil, false
+	}
+	return ids[n%len(ids)], true
+}
+
+// apply runs one operation. Operations the state cannot support (an
+// overdraft, releasing a settled escrow) must fail without moving money.
+func (w *world) apply(ctx context.Context, op scriptOp) error {
+	before := w.snapshot()
+	from, to := w.users[op.From], w.users[op.To]
+	amount, fee := decimal.New(op.Amount, -2), decimal.New(op.Fee, -2)
+	sender, receiver := w.wallets.wallets[w.walletOf[from]], w.wallets.wallets[w.walletOf[to]]
+
+	var err error
+	switch op.Kind {
+	case opPay:
+		tx := &domain.Transaction{
+			ID: uuid.New(), Reference: w.s.generateReference(),
+			SenderID: from, ReceiverID: to, SenderWalletID: &sender.ID, ReceiverWalletID: &receiver.ID,
+			Amount: amount, Currency: "MWK", ConvertedAmount: amount, ConvertedCurrency: "MWK",
+			ExchangeRate: decimal.NewFromInt(1), FeeAmount: fee, FeeCurrency: "MWK", NetAmount: amount,
+			Status: domain.TransactionStatusPendingSettlement, TransactionType: domain.TransactionTypePayment,
+		}
+		if err = w.s.processPayment(ctx, tx, sender, receiver, amount.Add(fee)); err == nil {
+			w.txs.Create(ctx, tx)
+			w.payments = append(w.payments, tx.ID)
+		}
+	case opEscrow:
+		var res *PaymentResponse
+		// Like payments, escrows skip initiation's checks and go
+		// straight to moving money.
+		res, err = w.s.openEscrow(ctx, &EscrowRequest{
+			SenderID: from, ReceiverID: to, Amount: amount, Currency: "MWK",
+			Condition: "delivery", Expiry: time.Now().Add(time.Hour),
+		}, sender, receiver)
+		if err == nil {
+			w.escrows = append(w.escrows, res.Transaction.ID)
+		}
+	case opRelease, opRefund:
+		id, ok := pick(w.escrows, op.Pick)
+		if !ok {
+			return nil
+		}
+		tx := w.txs.txs[id]
+		if op.Kind == opRelease {
+			err = w.s.ReleaseEscrow(ctx, id, tx.SenderID)
+		} else {
+			err = w.s.RefundEscrow(ctx, id, tx.SenderID)
+		}
+		if tx.Status != domain.TransactionStatusReserved && err == nil {
+			return fmt.Errorf("escrow %s settled twice", id)
+		}
+	case opReverse:
+		id, ok := pick(w.payments, op.Pick)
+		if !ok {
+			return nil
+		}
+		prior := w.txs.txs[id].Status
+		err = w.s.ReverseTransactionAdmin(ctx, id, uuid.New(), "property test")
+		if prior == domain.TransactionStatusReversed {
+			if err != nil {
+				return fmt.Errorf("repeat reversal of %s: %v", id, err)
+			}
+			if !reflect.DeepEqual(before, w.snapshot()) {
+				return fmt.Errorf("repeat reversal of %s moved money", id)
+			}
+		}
+	}
+	if err != nil && !reflect.DeepEqual(before, w.snapshot()) {
+		return fmt.Errorf("op %d failed (%v) but moved money", op.Kind, err)
+	}
+	return w.check()
+}
+
+// check asserts the wallet invariants: nothing negative, ledger balance is
+// available plus reserved, money is conserved, and exactly the open escrows
+// are held in reserve.
+func (w *world) check() error {
+	total, reserved := decimal.Zero, decimal.Zero
+	for id, wl := range w.snapshot() {
+		if wl.AvailableBalance.IsNegative() || wl.ReservedBalance.IsNegative() {
+			return fmt.Errorf("wallet %s negative: available %s, reserved %s", id, wl.AvailableBalance, wl.ReservedBalance)
+		}
+		if !wl.LedgerBalance.Equal(wl.AvailableBalance.Add(wl.ReservedBalance)) {
+			return fmt.Errorf("wallet %s: ledger %s != available %s + reserved %s", id, wl.LedgerBalance, wl.AvailableBalance, wl.ReservedBalance)
+		}
+		total = total.Add(wl.LedgerBalance)
+		reserved = reserved.Add(wl.ReservedBalance)
+	}
+	if !total.Equal(w.opening) {
+		return fmt.Errorf("money not conserved: %s, opened with %s", total, w.opening)
+	}
+	held := decimal.Zero
+	for _, id := range w.escrows {
+		if tx := w.txs.txs[id]; tx.Status == domain.TransactionStatusReserved {
+			held = held.Add(tx.Amount)
+		}
+	}
+	if !reserved.Equal(held) {
+		return fmt.Errorf("reserved %s, open escrows hold %s", reserved, held)
+	}
+	return nil
+}
+
+func TestProperty_WalletInvariants(t *testing.T) {
+	seed := time.Now().UnixNano()
+	cfg := &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(seed))}
+	ctx := context.Background()
+	var failure error
+	err := quick.Check(func(sc script) bool {
+		w := newWorld(sc)
+		for i, op := range sc.Ops {
+			if failure = w.apply(ctx, op); failure != nil {
+				failure = fmt.Errorf("op %d %+v: %v", i, op, failure)
+				return false
+			}
+		}
+		return true
+	}, cfg)
+	if err != nil {
+		t.Fatalf("seed %d: %v", seed, failure)
+	}
+}
@@ -0,0 +1,238 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// quoteKey is the metadata key of the quote a payment was converted under.
+const quoteKey = "quote_id"
+
+// quoteIDAttempts bounds the fresh IDs tried for a quote whose ID is taken.
+const quoteIDAttempts = 3
+
+var (
+	// ErrInvalidQuote is returned for a payment that cannot be quoted, such
+	// as one without a receiver.
+	ErrInvalidQuote = errors.New("invalid payment quote request")
+	// ErrQuoteNotFound is returned for a quote the sender was not issued.
+	ErrQuoteNotFound = errors.New("payment quote not found")
+	// ErrQuoteUnusable is returned when a payment cannot be made under its
+	// quote: it has expired or been used, or is for another payment.
+	ErrQuoteUnusable = errors.New("payment quote cannot be used")
+	// ErrQuotesDisabled is returned when quotes are not configured.
+	ErrQuotesDisabled = errors.New("payment quotes are not enabled")
+)
+
+// QuoteStore keeps quotes until they expire.
+type QuoteStore interface {
+	// Save keeps q for ttl, reporting false without overwriting anything
+	// when a quote with its ID is already kept.
+	Save(ctx context.Context, q *domain.PaymentQuote, ttl time.Duration) (bool, error)
+	// Get returns the quote, or nil once it has expired or been used.
+	Get(ctx context.Context, id uuid.UUID) (*domain.PaymentQuote, error)
+	// Delete drops the quote, reporting false when it was already gone.
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// WithQuotes lets senders price a payment before they make it and lock the
+// exchange rate for ttl: a payment initiated under the quote in that time
+// is converted at the quoted rate.
+func (s *Service) WithQuotes(store QuoteStore, ttl time.Duration) *Service {
+	s.quotes = store
+	s.quoteTTL = ttl
+	return s
+}
+
+// QuoteRequest describes a payment the sender wants priced; it identifies
+// the receiver as InitiatePaymentRequest does.
+type QuoteRequest struct {
+	SenderID              uuid.UUID       `json:"-"`
+	ReceiverID            uuid.UUID       `json:"receiver_id"`
+	ReceiverWalletAddress string          `json:"receiver_wallet_number"`
+	Amount                decimal.Decimal `json:"amount"`
+	Currency              domain.Currency `json:"currency"`
+	DestinationCurrency   domain.Currency `json:"destination_currency"`
+	Channel               string          `json:"channel"`
+}
+
+// Quote prices a payment at today's fees and exchange rate and keeps the
+// quote, so a payment made under it before it expires gets the same rate.
+func (s *Service) Quote(ctx context.Context, req *QuoteRequest) (*domain.PaymentQuote, error) {
+	if s.quotes == nil {
+		return nil, ErrQuotesDisabled
+	}
+	if !req.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidQuote)
+	}
+	if req.Currency == "" {
+		return nil, fmt.Errorf("%w: currency is required", ErrInvalidQuote)
+	}
+	if req.ReceiverWalletAddress == "" && req.ReceiverID == uuid.Nil {
+		return nil, fmt.Errorf("%w: receiver_wallet_number or receiver_id is required", ErrInvalidQuote)
+	}
+
+	parties, err := s.priceParties(ctx, req.SenderID, req.ReceiverID, req.ReceiverWalletAddress, req.Currency, req.DestinationCurrency)
+	if err != nil {
+		return nil, err
+	}
+	senderWallet, receiverWallet := parties.SenderWallet, parties.ReceiverWallet
+	fee, err := s.quoteFee(ctx, parties.Sender, req.Amount, senderWallet.Currency, receiverWallet.Currency, req.Channel)
+	if err != nil {
+		return nil, err
+	}
+	rate, midRate, err := s.priceRate(ctx, senderWallet.Currency, receiverWallet.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	q := &domain.PaymentQuote{
+		SenderID:            req.SenderID,
+		SenderWalletID:      senderWallet.ID,
+		ReceiverWalletID:    receiverWallet.ID,
+		Amount:              req.Amount,
+		Currency:            senderWallet.Currency,
+		ExchangeRate:        rate,
+		MidMarketRate:       midRate,
+		ConvertedAmount:     req.Amount.Mul(rate).Round(receiverWallet.Currency.MinorUnits()),
+		DestinationCurrency: receiverWallet.Currency,
+		Fees:                fee,
+		TotalDebit:          fee.TotalDebit,
+		IssuedAt:            now,
+		ExpiresAt:           now.Add(s.quoteTTL),
+	}
+	// Quotes are only ever added, never overwritten: an ID that is taken
+	// gets a fresh one, so no quote can be paid under another's rate.
+	for attempt := 0; attempt < quoteIDAttempts; attempt++ {
+		q.ID = uuid.New()
+		saved, err := s.quotes.Save(ctx, q, s.quoteTTL)
+		if err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to save payment quote")
+		}
+		if saved {
+			return q, nil
+		}
+	}
+	return nil, errors.New("failed to issue a unique payment quote ID")
+}
+
+// lockedQuote returns the quote a payment is made under, checking that it
+// was issued to the sender for this payment and has not expired.
+func (s *Service) lockedQuote(ctx context.Context, req *InitiatePaymentRequest, senderWallet, receiverWallet *domain.Wallet) (*domain.PaymentQuote, error) {
+	if s.quotes == nil {
+		return nil, ErrQuotesDisabled
+	}
+	q, err := s.quotes.Get(ctx, req.QuoteID)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to load payment quote")
+	}
+	if q == nil {
+		return nil, fmt.Errorf("%w: it has expired or was already used, request a new one", ErrQuoteUnusable)
+	}
+	// The stored quote must name itself and the sender, so that a key
+	// holding anything else is never honoured.
+	if q.ID != req.QuoteID || q.SenderID != req.SenderID {
+		return nil, ErrQuoteNotFound
+	}
+	switch {
+	case q.IsExpired(time.Now()):
+		return nil, fmt.Errorf("%w: it has expired, request a new one", ErrQuoteUnusable)
+	case q.SenderWalletID != senderWallet.ID || q.ReceiverWalletID != receiverWallet.ID ||
+		q.Currency != req.Currency || !q.Amount.Equal(req.Amount):
+		return nil, fmt.Errorf("%w: it is for a different payment", ErrQuoteUnusable)
+	}
+	return q, nil
+}
+
+// useQuote spends the quote on tx, recording it on tx. Only one payment
+// can be made under a quote; if tx then fails to be recorded the quote is
+// given back with restoreQuote.
+func (s *Service) useQuote(ctx context.Context, q *domain.PaymentQuote, tx *domain.Transaction) error {
+	used, err := s.quotes.Delete(ctx, q.ID)
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to use payment quote")
+	}
+	if !used {
+		return fmt.Errorf("%w: it was already used", ErrQuoteUnusable)
+	}
+	metadata := make(domain.Metadata, len(tx.Metadata)+1)
+	for k, v := range tx.Metadata {
+		metadata[k] = v
+	}
+	metadata[quoteKey] = q.ID.String()
+	tx.Metadata = metadata
+	return nil
+}
+
+// priceParties loads the sender and both wallets of a payment being priced.
+// The receiver's wallet is the one in the destination currency, or failing
+// that the one at the address.
+func (s *Service) priceParties(ctx context.Context, senderID, receiverID uuid.UUID, address string, currency, destination domain.Currency) (*domain.PaymentParties, error) {
+	if destination == "" {
+		destination = currency
+	}
+	parties, err := s.walletRepo.FindPaymentParties(ctx, domain.PaymentPartiesQuery{
+		SenderID:         senderID,
+		Currency:         currency,
+		ReceiverAddress:  address,
+		ReceiverID:       receiverID,
+		ReceiverCurrency: destination,
+	})
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to load payment parties")
+	}
+	if parties.SenderWallet == nil {
+		return nil, pkgerrors.Wrap(pkgerrors.ErrWalletNotFound, "sender wallet not found")
+	}
+	if parties.ReceiverWallet == nil {
+		if address == "" {
+			return nil, pkgerrors.Wrap(pkgerrors.ErrWalletNotFound, "receiver wallet not found for user")
+		}
+		if parties.ReceiverWallet, err = s.walletRepo.FindByAddress(ctx, address); err != nil {
+			return nil, pkgerrors.Wrap(err, "receiver wallet not found by address")
+		}
+	}
+	return parties, nil
+}
+
+// priceRate returns the sell rate a payment from one currency to another
+// is converted at now, and the mid-market rate it is priced off; both are 1
+// within one currency.
+func (s *Service) priceRate(ctx context.Context, from, to domain.Currency) (decimal.Decimal, decimal.Decimal, error) {
+	if from == to {
+		return decimal.NewFromInt(1), decimal.NewFromInt(1), nil
+	}
+	r, err := s.forexService.GetRate(ctx, from, to)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, pkgerrors.Wrap(err, "failed to get exchange rate")
+	}
+	return r.SellRate, r.Rate, nil
+}
+
+// restoreQuote gives back a quote spent on a payment that was never
+// recorded, for what is left of its lifetime. A quote that cannot be given
+// back is only logged: the sender can ask for a new one.
+func (s *Service) restoreQuote(ctx context.Context, q *domain.PaymentQuote) {
+	if q == nil {
+		return
+	}
+	ttl := time.Until(q.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+	if _, err := s.quotes.Save(ctx, q, ttl); err != nil {
+		s.logger.Warn("Failed to restore payment quote", map[string]interface{}{
+			"quote_id": q.ID,
+			"error":    err.Error(),
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const quoteKeyPrefix = "payment_quote:"
+
+// RedisQuoteStore keeps payment quotes in Redis until they expire, shared by
+// all services.
+type RedisQuoteStore struct {
+	client *redis.Client
+}
+
+func NewRedisQuoteStore(client *redis.Client) *RedisQuoteStore {
+	return &RedisQuoteStore{client: client}
+}
+
+func (s *RedisQuoteStore) Save(ctx context.Context, q *domain.PaymentQuote, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(q)
+	if err != nil {
+		return false, err
+	}
+	return s.client.SetNX(ctx, quoteKeyPrefix+q.ID.String(), data, ttl).Result()
+}
+
+func (s *RedisQuoteStore) Get(ctx context.Context, id uuid.UUID) (*domain.PaymentQuote, error) {
+	data, err := s.client.Get(ctx, quoteKeyPrefix+id.String()).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var q domain.PaymentQuote
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (s *RedisQuoteStore) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	n, err := s.client.Del(ctx, quoteKeyPrefix+id.String()).Result()
+	return n == 1, err
+}
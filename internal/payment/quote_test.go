@@ -0,0 +1,161 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memQuotes keeps quotes as Redis does, with taken lists IDs that are
+// already in use by quotes it does not hold.
+type memQuotes struct {
+	items map[uuid.UUID]domain.PaymentQuote
+	taken int
+}
+
+func (m *memQuotes) Save(ctx context.Context, q *domain.PaymentQuote, ttl time.Duration) (bool, error) {
+	if _, ok := m.items[q.ID]; ok || m.taken > 0 {
+		m.taken--
+		return false, nil
+	}
+	m.items[q.ID] = *q
+	return true, nil
+}
+
+func (m *memQuotes) Get(ctx context.Context, id uuid.UUID) (*domain.PaymentQuote, error) {
+	q, ok := m.items[id]
+	if !ok {
+		return nil, nil
+	}
+	return &q, nil
+}
+
+func (m *memQuotes) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	_, ok := m.items[id]
+	delete(m.items, id)
+	return ok, nil
+}
+
+// newQuoteWorld pays 100 MWK to a ZAR wallet at a sell rate of 0.011,
+// straight away.
+func newQuoteWorld() (*sagaWorld, *Service, *memQuotes) {
+	w := newSagaWorld(domain.ZAR)
+	w.cutOffs = nil
+	quotes := &memQuotes{items: map[uuid.UUID]domain.PaymentQuote{}}
+	return w, w.service("", nil).WithQuotes(quotes, time.Minute), quotes
+}
+
+func TestQuote(t *testing.T) {
+	ctx := context.Background()
+	w, s, quotes := newQuoteWorld()
+
+	_, err := s.Quote(ctx, &QuoteRequest{SenderID: w.sender.UserID, Amount: decimal.NewFromInt(100), Currency: domain.MWK})
+	assert.ErrorIs(t, err, ErrInvalidQuote, "no receiver")
+
+	quotes.taken = 1
+	q, err := s.Quote(ctx, &QuoteRequest{
+		SenderID:              w.sender.UserID,
+		ReceiverWalletAddress: *w.receiver.WalletAddress,
+		Amount:                decimal.NewFromInt(100),
+		Currency:              domain.MWK,
+		DestinationCurrency:   domain.ZAR,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, quotes.items, q.ID, "a taken ID is never overwritten, the quote gets another")
+	assert.Equal(t, w.receiver.ID, q.ReceiverWalletID)
+	assert.Equal(t, "0.011", q.ExchangeRate.String())
+	assert.Equal(t, "0.012", q.MidMarketRate.String())
+	assert.Equal(t, "1.1", q.ConvertedAmount.String())
+	assert.Equal(t, domain.ZAR, q.DestinationCurrency)
+	assert.Equal(t, "1.5", q.Fees.Fee.String(), "the standard 1.5% fee")
+	assert.Equal(t, "101.5", q.TotalDebit.String())
+	assert.Equal(t, time.Minute, q.ExpiresAt.Sub(q.IssuedAt))
+
+	// Amounts are kept to the destination currency's minor units.
+	jw := newSagaWorld(domain.JPY)
+	jw.cutOffs = nil
+	yen, err := jw.service("", nil).WithQuotes(quotes, time.Minute).Quote(ctx, &QuoteRequest{SenderID: jw.sender.UserID, ReceiverWalletAddress: *jw.receiver.WalletAddress, Amount: decimal.NewFromInt(100), Currency: domain.MWK})
+	require.NoError(t, err)
+	assert.Equal(t, "1", yen.ConvertedAmount.String())
+
+	quotes.taken = quoteIDAttempts
+	_, err = s.Quote(ctx, &QuoteRequest{SenderID: w.sender.UserID, ReceiverWalletAddress: *w.receiver.WalletAddress, Amount: decimal.NewFromInt(100), Currency: domain.MWK})
+	assert.Error(t, err, "no free ID")
+}
+
+func TestInitiatePaymentUnderQuote(t *testing.T) {
+	ctx := context.Background()
+	w, s, quotes := newQuoteWorld()
+	quote := func() *domain.PaymentQuote {
+		q, err := s.Quote(ctx, &QuoteRequest{SenderID: w.sender.UserID, ReceiverWalletAddress: *w.receiver.WalletAddress, Amount: decimal.NewFromInt(100), Currency: domain.MWK})
+		require.NoError(t, err)
+		return q
+	}
+	pay := func(quoteID uuid.UUID, amount int64) (*PaymentResponse, error) {
+		return s.InitiatePayment(ctx, &InitiatePaymentRequest{
+			SenderID:              w.sender.UserID,
+			ReceiverWalletAddress: *w.receiver.WalletAddress,
+			Amount:                decimal.NewFromInt(amount),
+			Currency:              domain.MWK,
+			DestinationCurrency:   domain.ZAR,
+			QuoteID:               quoteID,
+		})
+	}
+
+	q := quote()
+	// The market and fees move after the quote; the payment keeps what was
+	// quoted.
+	locked := quotes.items[q.ID]
+	locked.ExchangeRate, locked.ConvertedAmount = decimal.RequireFromString("0.02"), decimal.RequireFromString("2")
+	locked.Fees = &domain.FeeBreakdown{Fee: decimal.RequireFromString("0.75"), TotalDebit: decimal.RequireFromString("100.75")}
+	quotes.items[q.ID] = locked
+
+	_, err := pay(q.ID, 90)
+	assert.ErrorIs(t, err, ErrQuoteUnusable, "another amount")
+	_, err = pay(uuid.New(), 100)
+	assert.ErrorIs(t, err, ErrQuoteUnusable, "never issued, or gone")
+
+	// A payment that fails to be recorded gives the quote back.
+	w.txs.createErr = errors.New("db down")
+	_, err = pay(q.ID, 100)
+	assert.Error(t, err)
+	assert.Equal(t, locked, quotes.items[q.ID], "restored as it was")
+	w.txs.createErr = nil
+
+	res, err := pay(q.ID, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "0.02", res.Transaction.ExchangeRate.String())
+	assert.Equal(t, "2", res.Transaction.ConvertedAmount.String())
+	assert.Equal(t, "0.75", res.Transaction.FeeAmount.String(), "the quoted fee")
+	assert.Equal(t, "899.25", w.sender.AvailableBalance.String(), "debited the quoted total")
+	assert.Equal(t, q.ID.String(), res.Transaction.Metadata[quoteKey])
+	assert.Equal(t, "0.012", res.Transaction.Metadata[fxMidRateKey])
+	assert.NotContains(t, quotes.items, q.ID, "spent")
+
+	_, err = pay(q.ID, 100)
+	assert.ErrorIs(t, err, ErrQuoteUnusable, "already used")
+
+	expired := quote()
+	stale := quotes.items[expired.ID]
+	stale.ExpiresAt = time.Now().Add(-time.Second)
+	quotes.items[expired.ID] = stale
+	_, err = pay(expired.ID, 100)
+	assert.ErrorIs(t, err, ErrQuoteUnusable)
+
+	// A key holding a quote for someone else is not honoured.
+	other := quote()
+	foreign := quotes.items[other.ID]
+	foreign.SenderID = uuid.New()
+	quotes.items[other.ID] = foreign
+	_, err = pay(other.ID, 100)
+	assert.ErrorIs(t, err, ErrQuoteNotFound)
+	assert.Contains(t, quotes.items, other.ID, "a refused payment leaves the quote")
+}
@@ -0,0 +1,63 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+)
+
+// ErrNotResubmittable is returned for a transaction that is not a payment
+// waiting to be posted.
+var ErrNotResubmittable = errors.New("transaction is not a payment waiting to be posted")
+
+// ResubmitPayment posts a payment that stopped between being accepted and
+// reaching settlement. The ledger posting reuses the payment's idempotency
+// key, so a payment that was already posted is not debited again and only
+// moves on to settlement. A sender who can no longer cover the payment has
+// it failed, as nothing was debited.
+func (s *Service) ResubmitPayment(ctx context.Context, tx *domain.Transaction) error {
+	if tx.TransactionType != domain.TransactionTypePayment || tx.SenderWalletID == nil || tx.ReceiverWalletID == nil {
+		return ErrNotResubmittable
+	}
+	if tx.Status != domain.TransactionStatusPending && tx.Status != domain.TransactionStatusProcessing {
+		return ErrNotResubmittable
+	}
+	senderWallet, err := s.walletRepo.FindByID(ctx, *tx.SenderWalletID)
+	if err != nil {
+		return err
+	}
+	receiverWallet, err := s.walletRepo.FindByID(ctx, *tx.ReceiverWalletID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.processPayment(ctx, tx, senderWallet, receiverWallet, tx.Amount.Add(tx.FeeAmount)); err != nil {
+		if !errors.Is(err, pkgerrors.ErrInsufficientBalance) {
+			return err
+		}
+		tx.Status = domain.TransactionStatusFailed
+		tx.StatusReason = "Recovery: insufficient balance when resubmitted"
+		tx.UpdatedAt = now
+		if err := s.repo.Update(ctx, tx); err != nil {
+			return err
+		}
+		s.recordEvent(ctx, tx.ID, domain.TransactionEventFailed, domain.Metadata{"reason": "Your payment could not be completed and nothing was debited."})
+		return nil
+	}
+
+	tx.Status = domain.TransactionStatusPendingSettlement
+	tx.CompletedAt = &now
+	tx.UpdatedAt = now
+	if err := s.repo.Update(ctx, tx); err != nil {
+		return err
+	}
+	s.logger.Info("Stuck payment resubmitted", map[string]interface{}{
+		"transaction_id": tx.ID,
+		"reference":      tx.Reference,
+	})
+	return nil
+}
@@ -0,0 +1,294 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// InitiatePayment runs as a saga once its checks pass. The saga is written
+// before the transaction and advanced after each step that moves money:
+//
+//	started   -> transaction recorded -> recorded
+//	recorded  -> funds reserved (queued payments) -> reserved
+//	recorded  -> ledger posted -> posted -> transaction settling
+//
+// A payment interrupted by a crash leaves its saga running. Recovery reads
+// where the transaction actually got to and either completes it, when
+// money already moved, or compensates it by failing the transaction, when
+// none did. Posting is the point of no return: before it nothing needs
+// undoing, after it the payment is always carried forward.
+
+// sagaPageSize bounds how many sagas one recovery pass loads.
+const sagaPageSize = 100
+
+// SagaRepository persists payment sagas.
+type SagaRepository interface {
+	Begin(ctx context.Context, saga *domain.PaymentSaga) error
+	Advance(ctx context.Context, txID uuid.UUID, step domain.PaymentSagaStep) error
+	// ReserveFunds reserves amount in the wallet and advances the saga to
+	// reserved in one database transaction, so recovery knows for certain
+	// whether a queued payment holds funds.
+	ReserveFunds(ctx context.Context, txID, walletID uuid.UUID, amount decimal.Decimal) error
+	Finish(ctx context.Context, txID uuid.UUID, state domain.PaymentSagaState, outcome string) error
+	// FindRunning returns sagas still running that were last advanced
+	// before the given time, oldest first.
+	FindRunning(ctx context.Context, before time.Time, limit int) ([]*domain.PaymentSaga, error)
+	// Posted reports whether the ledger holds a posting under key.
+	Posted(ctx context.Context, key string) (bool, error)
+}
+
+// WithSagas makes InitiatePayment record its progress so that payments
+// interrupted by a crash are finished by RecoverPaymentSagas.
+func (s *Service) WithSagas(repo SagaRepository) *Service {
+	s.sagas = repo
+	return s
+}
+
+// paymentPostingKey is the ledger idempotency key of a payment's posting.
+func paymentPostingKey(txID uuid.UUID) string {
+	return "payment:" + txID.String()
+}
+
+// sagaBoundary marks a step boundary of the payment saga. Tests replace
+// crashAt to stop the saga there, as if the process had died.
+func (s *Service) sagaBoundary(name string) {
+	if s.crashAt != nil {
+		s.crashAt(name)
+	}
+}
+
+// beginSaga writes tx's saga before the transaction itself. Without a saga
+// the payment cannot be recovered, so failing to write one stops it.
+func (s *Service) beginSaga(ctx context.Context, tx *domain.Transaction, totalDebit decimal.Decimal) error {
+	if s.sagas == nil {
+		return nil
+	}
+	now := time.Now()
+	err := s.sagas.Begin(ctx, &domain.PaymentSaga{
+		TransactionID:  tx.ID,
+		Reference:      tx.Reference,
+		SenderWalletID: *tx.SenderWalletID,
+		TotalDebit:     totalDebit,
+		Currency:       tx.Currency,
+		Step:           domain.PaymentSagaStarted,
+		State:          domain.PaymentSagaRunning,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to start payment")
+	}
+	s.sagaBoundary("saga started")
+	return nil
+}
+
+// advanceSaga records that step took effect. A step that fails to record
+// is only logged: recovery checks where the transaction actually got to,
+// so the step is a hint rather than the truth.
+func (s *Service) advanceSaga(ctx context.Context, txID uuid.UUID, step domain.PaymentSagaStep) {
+	if s.sagas == nil {
+		return
+	}
+	if err := s.sagas.Advance(ctx, txID, step); err != nil {
+		s.logger.Error("Failed to advance payment saga", map[string]interface{}{
+			"transaction_id": txID,
+			"step":           step,
+			"error":          err.Error(),
+		})
+	}
+	s.sagaBoundary("saga " + string(step))
+}
+
+// finishSaga closes tx's saga; one that fails to close is finished again
+// by recovery.
+func (s *Service) finishSaga(ctx context.Context, txID uuid.UUID, state domain.PaymentSagaState, outcome string) {
+	if s.sagas == nil {
+		return
+	}
+	if err := s.sagas.Finish(ctx, txID, state, outcome); err != nil {
+		s.logger.Error("Failed to finish payment saga", map[string]interface{}{
+			"transaction_id": txID,
+			"state":          state,
+			"error":          err.Error(),
+		})
+	}
+}
+
+// reserveQueued reserves a queued payment's funds, through the saga when
+// there is one.
+func (s *Service) reserveQueued(ctx context.Context, tx *domain.Transaction, amount decimal.Decimal) error {
+	if s.sagas == nil {
+		return s.walletRepo.ReserveFunds(ctx, *tx.SenderWalletID, amount)
+	}
+	if err := s.sagas.ReserveFunds(ctx, tx.ID, *tx.SenderWalletID, amount); err != nil {
+		return err
+	}
+	s.sagaBoundary("saga " + string(domain.PaymentSagaReserved))
+	return nil
+}
+
+// RecoverPaymentSagas finishes payments whose saga was last advanced
+// before the given time and is still running, returning how many it
+// finished. before must leave room for initiations still in flight, or
+// recovery would race them. A saga that fails to recover is logged and
+// retried on the next pass.
+func (s *Service) RecoverPaymentSagas(ctx context.Context, before time.Time) (int, error) {
+	if s.sagas == nil {
+		return 0, nil
+	}
+	sagas, err := s.sagas.FindRunning(ctx, before, sagaPageSize)
+	if err != nil {
+		return 0, err
+	}
+	recovered := 0
+	for _, saga := range sagas {
+		state, outcome, err := s.recoverSaga(ctx, saga)
+		if err != nil {
+			s.logger.Error("Failed to recover payment saga", map[string]interface{}{
+				"transaction_id": saga.TransactionID,
+				"step":           saga.Step,
+				"error":          err.Error(),
+			})
+			continue
+		}
+		if err := s.sagas.Finish(ctx, saga.TransactionID, state, outcome); err != nil {
+			s.logger.Error("Failed to finish payment saga", map[string]interface{}{
+				"transaction_id": saga.TransactionID,
+				"error":          err.Error(),
+			})
+			continue
+		}
+		s.logger.Info("Payment saga recovered", map[string]interface{}{
+			"transaction_id": saga.TransactionID,
+			"step":           saga.Step,
+			"state":          state,
+			"outcome":        outcome,
+		})
+		recovered++
+	}
+	return recovered, nil
+}
+
+// recoverSaga completes or compensates one interrupted payment and returns
+// how its saga ends.
+func (s *Service) recoverSaga(ctx context.Context, saga *domain.PaymentSaga) (domain.PaymentSagaState, string, error) {
+	tx, err := s.repo.FindByID(ctx, saga.TransactionID)
+	if errors.Is(err, pkgerrors.ErrTransactionNotFound) {
+		return domain.PaymentSagaCompensated, "transaction was never recorded", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	switch tx.Status {
+	case domain.TransactionStatusPendingApproval:
+		return domain.PaymentSagaCompleted, "held for review", nil
+
+	case domain.TransactionStatusQueued:
+		// The reservation and the reserved step commit together.
+		if saga.Step == domain.PaymentSagaReserved {
+			return domain.PaymentSagaCompleted, "queued with funds reserved", nil
+		}
+		if err := s.failInterrupted(ctx, tx, "We could not queue your payment and nothing was reserved."); err != nil {
+			return "", "", err
+		}
+		return domain.PaymentSagaCompensated, "failed before funds were reserved", nil
+
+	case domain.TransactionStatusPending, domain.TransactionStatusProcessing:
+		posted, err := s.sagas.Posted(ctx, paymentPostingKey(tx.ID))
+		if err != nil {
+			return "", "", err
+		}
+		if !posted {
+			if err := s.failInterrupted(ctx, tx, "Your payment could not be completed and nothing was debited."); err != nil {
+				return "", "", err
+			}
+			return domain.PaymentSagaCompensated, "failed before the ledger posting", nil
+		}
+		now := time.Now()
+		tx.Status = domain.TransactionStatusPendingSettlement
+		tx.CompletedAt = &now
+		tx.UpdatedAt = now
+		if err := s.repo.Update(ctx, tx); err != nil {
+			return "", "", err
+		}
+		return domain.PaymentSagaCompleted, "posted; moved on to settlement", nil
+
+	case domain.TransactionStatusFailed, domain.TransactionStatusCancelled:
+		return domain.PaymentSagaCompensated, "transaction already " + string(tx.Status), nil
+	}
+	return domain.PaymentSagaCompleted, "transaction already " + string(tx.Status), nil
+}
+
+// failInterrupted fails a payment that was interrupted before any money
+// moved.
+func (s *Service) failInterrupted(ctx context.Context, tx *domain.Transaction, reason string) error {
+	tx.Status = domain.TransactionStatusFailed
+	tx.StatusReason = "Recovery: interrupted before any money moved"
+	tx.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, tx); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventFailed, domain.Metadata{"reason": reason})
+	return nil
+}
+
+// SagaRecoveryWorker recovers interrupted payments when it starts, picking
+// up after a crash, and then every interval.
+type SagaRecoveryWorker struct {
+	service  *Service
+	after    time.Duration
+	interval time.Duration
+	logger   logger.Logger
+	pulse    heartbeat.Pulse
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSagaRecoveryWorker recovers sagas left running for longer than after.
+func NewSagaRecoveryWorker(service *Service, after, interval time.Duration, log logger.Logger) *SagaRecoveryWorker {
+	return &SagaRecoveryWorker{service: service, after: after, interval: interval, logger: log, pulse: heartbeat.Nop, stop: make(chan struct{})}
+}
+
+// WithHeartbeat beats p after every pass.
+func (w *SagaRecoveryWorker) WithHeartbeat(p heartbeat.Pulse) *SagaRecoveryWorker {
+	w.pulse = p
+	return w
+}
+
+func (w *SagaRecoveryWorker) Start() {
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			n, err := w.service.RecoverPaymentSagas(context.Background(), time.Now().Add(-w.after))
+			if err != nil {
+				w.logger.Error("Failed to recover payment sagas", map[string]interface{}{"error": err.Error()})
+			} else if n > 0 {
+				w.logger.Info("Recovered interrupted payments", map[string]interface{}{"count": n})
+			}
+			w.pulse.Beat()
+			select {
+			case <-ticker.C:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	w.logger.Info("Payment saga recovery started", map[string]interface{}{"interval": w.interval.String()})
+}
+
+func (w *SagaRecoveryWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
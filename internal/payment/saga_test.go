@@ -0,0 +1,319 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/clock"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// sagaTransactions adds what initiation reads to memTransactions. Payments
+// fail to be recorded with createErr when it is set.
+type sagaTransactions struct {
+	*memTransactions
+	createErr error
+}
+
+func (m *sagaTransactions) Create(ctx context.Context, tx *domain.Transaction) error {
+	if m.createErr != nil {
+		return m.createErr
+	}
+	return m.memTransactions.Create(ctx, tx)
+}
+
+func (m *sagaTransactions) FindByReference(ctx context.Context, ref string) (*domain.Transaction, error) {
+	return nil, pkgerrors.ErrTransactionNotFound
+}
+
+func (m *sagaTransactions) GetDailyTotal(ctx context.Context, userID uuid.UUID, currency domain.Currency) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
+
+func (m *sagaTransactions) GetHourlyCount(ctx context.Context, userID uuid.UUID) (int, error) {
+	return 0, nil
+}
+
+// sagaWallets resolves the payment's parties from memWallets.
+type sagaWallets struct {
+	*memWallets
+	parties *domain.PaymentParties
+}
+
+func (m *sagaWallets) FindPaymentParties(ctx context.Context, q domain.PaymentPartiesQuery) (*domain.PaymentParties, error) {
+	return m.parties, nil
+}
+
+// memSagas keeps sagas the way the postgres repository does, reserving
+// funds and advancing the saga together.
+type memSagas struct {
+	sagas   map[uuid.UUID]*domain.PaymentSaga
+	wallets *memWallets
+	ledger  *memLedger
+}
+
+func (m *memSagas) Begin(ctx context.Context, saga *domain.PaymentSaga) error {
+	cp := *saga
+	m.sagas[saga.TransactionID] = &cp
+	return nil
+}
+
+func (m *memSagas) Advance(ctx context.Context, txID uuid.UUID, step domain.PaymentSagaStep) error {
+	if saga := m.sagas[txID]; saga != nil && saga.State == domain.PaymentSagaRunning {
+		saga.Step, saga.UpdatedAt = step, time.Now()
+	}
+	return nil
+}
+
+func (m *memSagas) ReserveFunds(ctx context.Context, txID, walletID uuid.UUID, amount decimal.Decimal) error {
+	if err := m.wallets.ReserveFunds(ctx, walletID, amount); err != nil {
+		return err
+	}
+	m.sagas[txID].Step, m.sagas[txID].UpdatedAt = domain.PaymentSagaReserved, time.Now()
+	return nil
+}
+
+func (m *memSagas) Finish(ctx context.Context, txID uuid.UUID, state domain.PaymentSagaState, outcome string) error {
+	if saga := m.sagas[txID]; saga != nil && saga.State == domain.PaymentSagaRunning {
+		saga.State, saga.Outcome = state, outcome
+	}
+	return nil
+}
+
+func (m *memSagas) FindRunning(ctx context.Context, before time.Time, limit int) ([]*domain.PaymentSaga, error) {
+	var out []*domain.PaymentSaga
+	for _, saga := range m.sagas {
+		if saga.State == domain.PaymentSagaRunning && saga.UpdatedAt.Before(before) {
+			cp := *saga
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memSagas) Posted(ctx context.Context, key string) (bool, error) {
+	return m.ledger.posted[key], nil
+}
+
+// crash stands in for the process dying at a saga step boundary.
+type crash struct{ boundary string }
+
+// sagaWorld is in-memory state that outlives the services run against it,
+// so a payment can be killed part way and recovered by a fresh service.
+type sagaWorld struct {
+	txs      *sagaTransactions
+	wallets  *sagaWallets
+	ledger   *memLedger
+	sagas    *memSagas
+	sender   *domain.Wallet
+	receiver *domain.Wallet
+	cutOffs  []CutOffWindow
+}
+
+func newSagaWorld(receiverCurrency domain.Currency) *sagaWorld {
+	mw := &memWallets{wallets: make(map[uuid.UUID]*domain.Wallet)}
+	opening := decimal.NewFromInt(1000)
+	address := "1234567890123456"
+	sender := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.MWK, AvailableBalance: opening, LedgerBalance: opening, ReservedBalance: decimal.Zero}
+	receiver := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: receiverCurrency, WalletAddress: &address, AvailableBalance: decimal.Zero, LedgerBalance: decimal.Zero, ReservedBalance: decimal.Zero}
+	mw.wallets[sender.ID], mw.wallets[receiver.ID] = sender, receiver
+	l := &memLedger{wallets: mw, posted: make(map[string]bool)}
+
+	w := &sagaWorld{
+		txs:      &sagaTransactions{memTransactions: &memTransactions{txs: make(map[uuid.UUID]domain.Transaction)}},
+		ledger:   l,
+		sagas:    &memSagas{sagas: make(map[uuid.UUID]*domain.PaymentSaga), wallets: mw, ledger: l},
+		sender:   sender,
+		receiver: receiver,
+	}
+	w.wallets = &sagaWallets{memWallets: mw, parties: &domain.PaymentParties{
+		Sender:         &domain.User{ID: sender.UserID, KYCStatus: domain.KYCStatusVerified, KYCLevel: 3},
+		SenderWallet:   sender,
+		ReceiverWallet: receiver,
+	}}
+	if receiverCurrency != domain.MWK {
+		// A one-minute window two hours from now is closed, so the
+		// payment queues.
+		now := time.Now().In(clock.Business())
+		open := (time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + 2*time.Hour) % (24 * time.Hour)
+		w.cutOffs = []CutOffWindow{{Corridor: "*", Open: open, Close: open + time.Minute}}
+	}
+	return w
+}
+
+// service starts a payment service on the world's state that dies at
+// boundary, or never when boundary is empty.
+func (w *sagaWorld) service(boundary string, seen *[]string) *Service {
+	security := new(MockSecurityRepository)
+	security.On("IsBlacklisted", mock.Anything, mock.Anything).Return(false, nil)
+	notifier := new(MockNotificationService)
+	notifier.On("Notify", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	forex := new(MockForexService)
+	forex.On("GetRate", mock.Anything, mock.Anything, mock.Anything).Return(&domain.ExchangeRate{Rate: decimal.NewFromFloat(0.012), SellRate: decimal.NewFromFloat(0.011)}, nil)
+
+	s := NewService(w.txs, w.wallets, forex, w.ledger, new(MockUserRepository), notifier, nil, security, logger.NewNop(), nil).
+		WithSagas(w.sagas)
+	if w.cutOffs != nil {
+		s.WithCutOffs(w.cutOffs, &memEscrows{txs: map[uuid.UUID]*domain.Transaction{}})
+	}
+	s.crashAt = func(b string) {
+		if seen != nil {
+			*seen = append(*seen, b)
+		}
+		if b == boundary {
+			panic(crash{b})
+		}
+	}
+	return s
+}
+
+// pay initiates a payment of 100 MWK (101.50 with the fee), reporting
+// whether the service died part way.
+func (w *sagaWorld) pay(t *testing.T, s *Service) (crashed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(crash); !ok {
+				panic(r)
+			}
+			crashed = true
+		}
+	}()
+	_, err := s.InitiatePayment(context.Background(), &InitiatePaymentRequest{
+		SenderID:              w.sender.UserID,
+		ReceiverWalletAddress: *w.receiver.WalletAddress,
+		Amount:                decimal.NewFromInt(100),
+		Currency:              domain.MWK,
+		DestinationCurrency:   w.receiver.Currency,
+	})
+	require.NoError(t, err)
+	return false
+}
+
+// boundaries runs one payment to the end and lists the step boundaries it
+// passed.
+func boundaries(t *testing.T, receiverCurrency domain.Currency) []string {
+	w := newSagaWorld(receiverCurrency)
+	var seen []string
+	require.False(t, w.pay(t, w.service("", &seen)))
+	require.Len(t, w.sagas.sagas, 1)
+	for _, saga := range w.sagas.sagas {
+		require.Equal(t, domain.PaymentSagaCompleted, saga.State, "a payment that is not interrupted finishes its saga")
+	}
+	return seen
+}
+
+func TestPaymentSaga_RecoversFromCrashAtEveryBoundary(t *testing.T) {
+	debit := decimal.NewFromFloat(101.5)
+	cases := []struct {
+		name     string
+		currency domain.Currency
+		// the transaction's status after recovering from a crash at each
+		// boundary, "" when it was never recorded
+		want map[string]domain.TransactionStatus
+	}{
+		{
+			name:     "immediate",
+			currency: domain.MWK,
+			want: map[string]domain.TransactionStatus{
+				"saga started":         "",
+				"transaction recorded": domain.TransactionStatusFailed,
+				"saga recorded":        domain.TransactionStatusFailed,
+				"ledger posted":        domain.TransactionStatusPendingSettlement,
+				"saga posted":          domain.TransactionStatusPendingSettlement,
+				"transaction settling": domain.TransactionStatusPendingSettlement,
+			},
+		},
+		{
+			name:     "queued",
+			currency: domain.ZAR,
+			want: map[string]domain.TransactionStatus{
+				"saga started":         "",
+				"transaction recorded": domain.TransactionStatusFailed,
+				"saga recorded":        domain.TransactionStatusFailed,
+				"saga reserved":        domain.TransactionStatusQueued,
+			},
+		},
+	}
+	for _, tc := range cases {
+		seen := boundaries(t, tc.currency)
+		require.Len(t, seen, len(tc.want), "%s passes every boundary under test: %v", tc.name, seen)
+
+		for _, boundary := range seen {
+			t.Run(tc.name+"/"+boundary, func(t *testing.T) {
+				ctx := context.Background()
+				w := newSagaWorld(tc.currency)
+				require.True(t, w.pay(t, w.service(boundary, nil)), "the service dies at %q", boundary)
+
+				restarted := w.service("", nil)
+				n, err := restarted.RecoverPaymentSagas(ctx, time.Now().Add(time.Minute))
+				require.NoError(t, err)
+				assert.Equal(t, 1, n)
+
+				var saga *domain.PaymentSaga
+				for _, sg := range w.sagas.sagas {
+					saga = sg
+				}
+				want := tc.want[boundary]
+				tx, stored := w.txs.txs[saga.TransactionID]
+				sender := w.wallets.wallets[w.sender.ID]
+				receiver := w.wallets.wallets[w.receiver.ID]
+
+				switch want {
+				case "":
+					assert.False(t, stored)
+					assert.Equal(t, domain.PaymentSagaCompensated, saga.State)
+				case domain.TransactionStatusFailed:
+					assert.Equal(t, want, tx.Status)
+					assert.Equal(t, domain.PaymentSagaCompensated, saga.State)
+				default:
+					assert.Equal(t, want, tx.Status)
+					assert.Equal(t, domain.PaymentSagaCompleted, saga.State)
+				}
+
+				// Money moved exactly when the payment went ahead.
+				switch want {
+				case domain.TransactionStatusPendingSettlement:
+					assert.True(t, sender.AvailableBalance.Equal(decimal.NewFromInt(1000).Sub(debit)), "sender debited once")
+					assert.True(t, receiver.LedgerBalance.Equal(decimal.NewFromInt(100)))
+				case domain.TransactionStatusQueued:
+					assert.True(t, sender.ReservedBalance.Equal(debit), "queued funds stay reserved")
+					assert.True(t, sender.LedgerBalance.Equal(decimal.NewFromInt(1000)))
+				default:
+					assert.True(t, sender.AvailableBalance.Equal(decimal.NewFromInt(1000)), "nothing debited or reserved")
+					assert.True(t, sender.ReservedBalance.IsZero())
+					assert.True(t, receiver.LedgerBalance.IsZero())
+				}
+				assert.True(t, sender.LedgerBalance.Equal(sender.AvailableBalance.Add(sender.ReservedBalance)))
+
+				// Recovery is done; a second pass finds nothing.
+				n, err = restarted.RecoverPaymentSagas(ctx, time.Now().Add(time.Minute))
+				require.NoError(t, err)
+				assert.Zero(t, n)
+			})
+		}
+	}
+}
+
+func TestRecoverPaymentSagas_LeavesRecentSagas(t *testing.T) {
+	ctx := context.Background()
+	w := newSagaWorld(domain.MWK)
+	require.True(t, w.pay(t, w.service("saga recorded", nil)))
+
+	// A saga advanced after the cut-off may still be in flight elsewhere.
+	s := w.service("", nil)
+	n, err := s.RecoverPaymentSagas(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	for _, saga := range w.sagas.sagas {
+		assert.Equal(t, domain.PaymentSagaRunning, saga.State)
+	}
+}
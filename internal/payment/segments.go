@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/risk"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// SegmentPolicySource reports how the sender's segments change a payment.
+type SegmentPolicySource interface {
+	PolicyFor(ctx context.Context, userID uuid.UUID) (*domain.SegmentPolicy, error)
+}
+
+// WithSegments applies segment fee rates and risk adjustments to payments.
+func (s *Service) WithSegments(source SegmentPolicySource) *Service {
+	s.segments = source
+	return s
+}
+
+// FeeRate is the fee rate userID's payments are charged now, as a fraction
+// of the amount sent.
+func (s *Service) FeeRate(ctx context.Context, userID uuid.UUID) (decimal.Decimal, error) {
+	var (
+		ent    *domain.Entitlements
+		policy *domain.SegmentPolicy
+		err    error
+	)
+	if s.entitlements != nil {
+		if ent, err = s.entitlements.Entitlements(ctx, userID); err != nil {
+			return decimal.Zero, err
+		}
+	}
+	if s.segments != nil {
+		if policy, err = s.segments.PolicyFor(ctx, userID); err != nil {
+			return decimal.Zero, err
+		}
+	}
+	return paymentFeeRate(ent, policy), nil
+}
+
+// paymentFeeRate is the segment fee rate when the sender has one, and the
+// plan's otherwise.
+func paymentFeeRate(ent *domain.Entitlements, policy *domain.SegmentPolicy) decimal.Decimal {
+	if policy != nil && policy.FeeRate != nil {
+		return *policy.FeeRate
+	}
+	return feeRate(ent)
+}
+
+// adjustRisk adds the segments' risk adjustment, keeping the score within
+// the engine's range.
+func adjustRisk(score risk.RiskScore, policy *domain.SegmentPolicy) risk.RiskScore {
+	if policy == nil || policy.RiskAdjustment == 0 {
+		return score
+	}
+	score += risk.RiskScore(policy.RiskAdjustment)
+	if score < risk.RiskScoreLow {
+		return risk.RiskScoreLow
+	}
+	if score > risk.RiskScoreCritical {
+		return risk.RiskScoreCritical
+	}
+	return score
+}
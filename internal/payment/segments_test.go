@@ -0,0 +1,27 @@
+package payment
+
+import (
+	"testing"
+
+	"kyd/internal/domain"
+	"kyd/internal/risk"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentFeeRate(t *testing.T) {
+	plan := &domain.Entitlements{FeeRate: decimal.NewFromFloat(0.01)}
+	segmentRate := decimal.NewFromFloat(0.005)
+
+	assert.True(t, standardFeeRate.Equal(paymentFeeRate(nil, nil)))
+	assert.True(t, plan.FeeRate.Equal(paymentFeeRate(plan, &domain.SegmentPolicy{RiskAdjustment: 10})))
+	assert.True(t, segmentRate.Equal(paymentFeeRate(plan, &domain.SegmentPolicy{FeeRate: &segmentRate})))
+}
+
+func TestAdjustRisk(t *testing.T) {
+	assert.Equal(t, risk.RiskScore(40), adjustRisk(40, nil))
+	assert.Equal(t, risk.RiskScore(60), adjustRisk(40, &domain.SegmentPolicy{RiskAdjustment: 20}))
+	assert.Equal(t, risk.RiskScoreCritical, adjustRisk(90, &domain.SegmentPolicy{RiskAdjustment: 30}))
+	assert.Equal(t, risk.RiskScoreLow, adjustRisk(10, &domain.SegmentPolicy{RiskAdjustment: -50}))
+}
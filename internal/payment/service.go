@@ -26,9 +26,12 @@ import (
 	"kyd/internal/monitoring"
 	"kyd/internal/notification"
 	"kyd/internal/risk"
+	"kyd/pkg/clock"
 	"kyd/pkg/config"
 	pkgerrors "kyd/pkg/errors"
 	"kyd/pkg/logger"
+	"kyd/pkg/metrics"
+	"kyd/pkg/reference"
 	"kyd/pkg/validator"
 )
 
@@ -39,6 +42,8 @@ type TransactionDetail struct {
 	SenderWalletNumber   string `json:"sender_wallet_number,omitempty"`
 	ReceiverWalletNumber string `json:"receiver_wallet_number,omitempty"`
 	BlockchainStatus     string `json:"blockchain_status,omitempty"`
+	// Note is the requesting customer's private note; see SetUserNote.
+	Note *domain.TransactionNote `json:"note,omitempty"`
 }
 
 type RiskUsageMetrics struct {
@@ -55,18 +60,48 @@ type RiskUsageMetrics struct {
 }
 
 type Service struct {
-	repo          Repository
-	walletRepo    WalletRepository
-	forexService  ForexService
-	ledgerService LedgerService
-	userRepo      UserRepository
-	logger        logger.Logger
-	riskEngine    *risk.RiskEngine
-	monitor       *monitoring.BehavioralMonitor
-	notifier      notification.Service
-	auditRepo     AuditRepository
-	securityRepo  SecurityRepository
+	repo               Repository
+	walletRepo         WalletRepository
+	forexService       ForexService
+	ledgerService      LedgerService
+	userRepo           UserRepository
+	logger             logger.Logger
+	riskEngine         *risk.RiskEngine
+	monitor            *monitoring.BehavioralMonitor
+	notifier           notification.Service
+	auditRepo          AuditRepository
+	securityRepo       SecurityRepository
+	events             EventRepository
+	notes              NoteRepository
+	controls           SpendingControlRepository
+	beneficiaries      TrustedBeneficiaryRepository
+	stepUp             StepUpVerifier
+	stepUpPolicy       StepUpPolicy
+	entitlements       EntitlementChecker
+	segments           SegmentPolicySource
+	feeSchedules       FeeScheduleSource
+	usage              UsageMeter
+	escrows            EscrowRepository
+	escrowLedger       EscrowLedger
+	freezes            FreezeChecker
+	jurisdictions      JurisdictionChecker
+	cutOffs            []CutOffWindow
+	queue              QueueRepository
+	sagas              SagaRepository
+	disclosures        DisclosureRepository
+	delivery           DeliveryEstimator
+	disclosureValidity time.Duration
+	quotes             QuoteStore
+	quoteTTL           time.Duration
+	balanceHistory     BalanceHistory
+	stateCache         AccountStateCache
+	stateCacheTTL      time.Duration
+	initiationBudget   time.Duration
 	feeCollectorUserID *uuid.UUID
+
+	// crashAt is called at each step boundary of the payment saga; tests
+	// set it to stop the saga as if the process had died.
+	crashAt func(boundary string)
 }
 
 func NewService(
@@ -103,18 +138,19 @@ func NewService(
 	}
 
 	return &Service{
-		repo:          repo,
-		walletRepo:    walletRepo,
-		forexService:  forexService,
-		ledgerService: ledgerService,
-		userRepo:      userRepo,
-		logger:        log,
-		riskEngine:    risk.NewRiskEngine(riskCfg),
-		monitor:       monitoring.NewBehavioralMonitor(),
-		notifier:      notifier,
-		auditRepo:     auditRepo,
-		securityRepo:  securityRepo,
+		repo:               repo,
+		walletRepo:         walletRepo,
+		forexService:       forexService,
+		ledgerService:      ledgerService,
+		userRepo:           userRepo,
+		logger:             log,
+		riskEngine:         risk.NewRiskEngine(riskCfg),
+		monitor:            monitoring.NewBehavioralMonitor(),
+		notifier:           notifier,
+		auditRepo:          auditRepo,
+		securityRepo:       securityRepo,
 		feeCollectorUserID: feeCollectorUserID,
+		initiationBudget:   DefaultInitiationBudget,
 	}
 }
 
@@ -183,106 +219,46 @@ type InitiatePaymentRequest struct {
 	DeviceID              string                 `json:"device_id"`
 	Location              string                 `json:"location"`
 	Metadata              map[string]interface{} `json:"metadata"`
+	StepUp                *StepUp                `json:"step_up,omitempty"`          // re-authentication for payments that need it
+	DisclosureID          uuid.UUID              `json:"disclosure_id" validate:"-"` // fee and rate disclosure the sender was shown
+	QuoteID               uuid.UUID              `json:"quote_id" validate:"-"`      // quote whose exchange rate the payment is converted at
 }
 
 type PaymentResponse struct {
 	Transaction *domain.Transaction `json:"transaction"`
 	Message     string              `json:"message"`
+	// QueuedUntil is when a payment queued after its corridor's cut-off
+	// is expected to go out.
+	QueuedUntil *time.Time `json:"queued_until,omitempty"`
 }
 
 // InitiatePayment handles the complete payment flow
 func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentRequest) (*PaymentResponse, error) {
+	defer s.observeInitiation(time.Now(), req)
+
+	res, err := s.initiatePayment(ctx, req)
+	status := ""
+	if res != nil && res.Transaction != nil {
+		status = string(res.Transaction.Status)
+	}
+	metrics.ObservePayment(status, string(req.Currency), err)
+	return res, err
+}
+
+func (s *Service) initiatePayment(ctx context.Context, req *InitiatePaymentRequest) (*PaymentResponse, error) {
 	// 0. Global Circuit Breaker Check
 	if err := s.riskEngine.CheckGlobalCircuitBreaker(); err != nil {
 		s.logger.Error("Payment blocked by circuit breaker", map[string]interface{}{"error": err.Error()})
 		return nil, err
 	}
 
-	// 0.05 Check Blocklist (Sender)
-	if isBlocked, err := s.securityRepo.IsBlacklisted(ctx, req.SenderID.String()); err != nil {
-		s.logger.Error("Failed to check blocklist", map[string]interface{}{"error": err.Error()})
-		return nil, errors.New("system error: unable to verify security status")
-	} else if isBlocked {
-		s.logger.Warn("Transaction blocked: Sender is blacklisted", map[string]interface{}{"sender_id": req.SenderID})
-		return nil, errors.New("security alert: account is restricted")
-	}
-
-	// 0.06 Check Blocklist (Receiver ID)
-	if req.ReceiverID != uuid.Nil {
-		if isBlocked, err := s.securityRepo.IsBlacklisted(ctx, req.ReceiverID.String()); err != nil {
-			s.logger.Error("Failed to check blocklist", map[string]interface{}{"error": err.Error()})
-			return nil, errors.New("system error: unable to verify security status")
-		} else if isBlocked {
-			s.logger.Warn("Transaction blocked: Receiver is blacklisted", map[string]interface{}{"receiver_id": req.ReceiverID})
-			return nil, errors.New("security alert: receiver account is restricted")
-		}
-	}
-
-	// 0.07 Check Blocklist (Receiver Wallet Address)
-	if req.ReceiverWalletAddress != "" {
-		if isBlocked, err := s.securityRepo.IsBlacklisted(ctx, req.ReceiverWalletAddress); err != nil {
-			s.logger.Error("Failed to check blocklist", map[string]interface{}{"error": err.Error()})
-			return nil, errors.New("system error: unable to verify security status")
-		} else if isBlocked {
-			s.logger.Warn("Transaction blocked: Receiver wallet is blacklisted", map[string]interface{}{"wallet": req.ReceiverWalletAddress})
-			return nil, errors.New("security alert: receiver wallet is restricted")
-		}
-	}
-
-	// 0.1 Check Daily Limit
-	dailyTotal, err := s.repo.GetDailyTotal(ctx, req.SenderID, req.Currency)
+	// 0.01 Load blocklist state, limits and both parties concurrently
+	pre, err := s.preloadInitiation(ctx, req)
 	if err != nil {
-		// If we can't fetch daily total, we should probably fail safe or log warning
-		// For banking safety, we fail open but log error, or fail closed?
-		// Fail closed (secure) is better.
-		s.logger.Error("Failed to fetch daily total", map[string]interface{}{"error": err.Error()})
-		return nil, pkgerrors.Wrap(err, "failed to verify daily limit")
-	}
-
-	if err := s.riskEngine.CheckDailyLimit(req.Amount, dailyTotal); err != nil {
-		s.logger.Warn("Transaction blocked by daily limit", map[string]interface{}{
-			"amount":      req.Amount.String(),
-			"daily_total": dailyTotal.String(),
-			"sender_id":   req.SenderID,
-		})
-
-		go func() {
-			_ = s.notifier.Notify(context.Background(), req.SenderID, "RISK_ALERT", map[string]interface{}{
-				"reason": "Daily transaction limit exceeded",
-				"limit":  s.riskEngine.GetConfig().MaxDailyLimit,
-			})
-		}()
-
-		// Log Security Event
-		go func() {
-			_ = s.securityRepo.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
-				Type:        "risk_block",
-				Severity:    "high",
-				Description: fmt.Sprintf("Daily limit exceeded. Amount: %s. Total: %s", req.Amount.String(), dailyTotal.String()),
-				Status:      "blocked",
-				UserID:      &req.SenderID,
-				IPAddress:   req.Location,
-				CreatedAt:   time.Now(),
-			})
-		}()
-
 		return nil, err
 	}
 
-	// 0.2 Cool-off Check
-	if err := s.riskEngine.CheckCoolOff(req.SenderID, req.Amount); err != nil {
-		s.logger.Warn("Transaction blocked by cool-off", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return nil, err
-	}
-
-	// 0.3 Restricted Country Check
-	if err := s.riskEngine.CheckRestrictedCountry(req.Location); err != nil {
-		s.logger.Warn("Transaction blocked by restricted country", map[string]interface{}{
-			"location":  req.Location,
-			"sender_id": req.SenderID,
-		})
+	if err := s.screenPayment(req, pre); err != nil {
 		return nil, err
 	}
 
@@ -367,214 +343,32 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 		return nil, errors.New("amount must be greater than zero")
 	}
 
-	// 1. Get sender and receiver wallets
-	senderWallet, err := s.walletRepo.FindByUserAndCurrency(ctx, req.SenderID, req.Currency)
-	if err != nil {
-		return nil, pkgerrors.Wrap(err, "sender wallet not found")
-	}
-
-	// 1b. Validate Sender KYC Status & Limits
-	sender, err := s.userRepo.FindByID(ctx, req.SenderID)
+	// 1. Check both parties and resolve the receiver's wallet
+	senderWallet, receiverWallet, err := s.clearPayment(ctx, req, pre)
 	if err != nil {
-		return nil, pkgerrors.Wrap(err, "failed to fetch sender profile")
-	}
-
-	if sender.KYCStatus != domain.KYCStatusVerified {
-		return nil, errors.New("KYC verification required to send funds")
-	}
-
-	// Define limits based on KYC Level
-	// Note: In a production environment, limits should be normalized to a base currency.
-	// Current implementation assumes limits apply to the transaction currency directly.
-	var limit decimal.Decimal
-	switch sender.KYCLevel {
-	case 1:
-		limit = decimal.NewFromInt(5000000) // Tier 1: 5M limit (increased for testing)
-	case 2:
-		limit = decimal.NewFromInt(10000000) // Tier 2: 10M limit
-	case 3:
-		limit = decimal.NewFromInt(100000000) // Tier 3: 100M limit
-	default:
-		limit = decimal.NewFromInt(0) // Tier 0: No sending
-	}
-
-	if sender.KYCLevel == 0 {
-		return nil, errors.New("KYC Level 1 required to transact")
-	}
-
-	if req.Amount.GreaterThan(limit) {
-		return nil, fmt.Errorf("transaction amount exceeds your KYC Level %d limit of %s", sender.KYCLevel, limit.String())
-	}
-
-	// 1c. Check Daily Velocity Limit
-	// dailyTotal is already fetched at the beginning of the function
-
-	var dailyLimit decimal.Decimal
-	switch sender.KYCLevel {
-	case 1:
-		dailyLimit = decimal.NewFromInt(10000000) // Tier 1: 10M Daily
-	case 2:
-		dailyLimit = decimal.NewFromInt(50000000) // Tier 2: 50M Daily
-	case 3:
-		dailyLimit = decimal.NewFromInt(500000000) // Tier 3: 500M Daily
-	default:
-		dailyLimit = decimal.Zero
-	}
-
-	if dailyTotal.Add(req.Amount).GreaterThan(dailyLimit) {
-		return nil, fmt.Errorf("daily transaction limit of %s exceeded (used: %s)", dailyLimit.String(), dailyTotal.String())
-	}
-
-	// 1d. Check Hourly Velocity (Fraud Detection)
-	// General Velocity Check
-	hourlyCount, err := s.repo.GetHourlyCount(ctx, req.SenderID)
-	if err != nil {
-		return nil, pkgerrors.Wrap(err, "failed to check velocity")
-	}
-	if err := s.riskEngine.CheckVelocity(hourlyCount); err != nil {
-		s.logger.Warn("Transaction blocked by velocity check", map[string]interface{}{
-			"user_id":      req.SenderID,
-			"hourly_count": hourlyCount,
-		})
-		// Log Security Event
-		go func() {
-			_ = s.securityRepo.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
-				Type:        "risk_block",
-				Severity:    "medium",
-				Description: fmt.Sprintf("Velocity limit exceeded. Hourly Count: %d", hourlyCount),
-				Status:      "blocked",
-				UserID:      &req.SenderID,
-				IPAddress:   req.Location,
-				CreatedAt:   time.Now(),
-			})
-		}()
-		return nil, err
-	}
-
-	// Max 3 transactions > HighValueThreshold per hour
-	highValueThreshold := decimal.NewFromInt(s.riskEngine.GetConfig().HighValueThreshold)
-	if req.Amount.GreaterThan(highValueThreshold) {
-		count, err := s.repo.GetHourlyHighValueCount(ctx, req.SenderID, highValueThreshold)
-		if err != nil {
-			return nil, pkgerrors.Wrap(err, "failed to check hourly velocity")
-		}
-		if count >= 3 {
-			return nil, errors.New("velocity limit exceeded: too many high-value transactions in the last hour")
-		}
-	}
-
-	// 1e. Advanced Risk Analysis & Cool-off
-	if err := s.riskEngine.CheckCoolOff(req.SenderID, req.Amount); err != nil {
 		return nil, err
 	}
-
-	// 1f. Behavioral Anomaly Detection
-	anomalies, err := s.monitor.DetectAnomalies(req.SenderID, req.Amount, req.ReceiverID.String())
-	if err == nil && len(anomalies) > 0 {
-		for _, anomaly := range anomalies {
-			s.logger.Warn("Behavioral anomaly detected", map[string]interface{}{
-				"user_id":     req.SenderID,
-				"type":        anomaly.Type,
-				"description": anomaly.Description,
-				"severity":    anomaly.Severity,
-			})
-
-			// If HIGH severity, block or require 2FA (for now, block)
-			if anomaly.Severity == "HIGH" {
-				// Notify user
-				go func() {
-					_ = s.notifier.Notify(context.Background(), req.SenderID, "SECURITY_ALERT", map[string]interface{}{
-						"reason": anomaly.Description,
-					})
-				}()
-				return nil, fmt.Errorf("security alert: %s", anomaly.Description)
-			}
-		}
-	}
-
-	accountAgeDays := int(time.Since(sender.CreatedAt).Hours() / 24)
-	if accountAgeDays < 0 {
-		accountAgeDays = 0
-	}
-	riskScore := s.riskEngine.EvaluateRisk(req.Amount, sender.KYCLevel, false, req.Location, accountAgeDays)
-	if riskScore >= risk.RiskScoreCritical {
-		s.logger.Error("Transaction blocked due to CRITICAL risk score", map[string]interface{}{
-			"risk_score": riskScore,
-			"amount":     req.Amount.String(),
-			"sender_id":  req.SenderID,
-		})
-
-		go func() {
-			_ = s.notifier.Notify(context.Background(), req.SenderID, "RISK_ALERT", map[string]interface{}{
-				"reason": "Transaction blocked due to high risk score",
-				"amount": req.Amount.String(),
-			})
-		}()
-
-		go func() {
-			_ = s.securityRepo.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
-				Type:        "risk_block",
-				Severity:    "critical",
-				Description: fmt.Sprintf("Transaction blocked. Risk Score: %d. Amount: %s", riskScore, req.Amount.String()),
-				Status:      "blocked",
-				UserID:      &req.SenderID,
-				IPAddress:   req.Location,
-				CreatedAt:   time.Now(),
-			})
-		}()
-
-		if s.securityRepo != nil {
-			go func(userID uuid.UUID, amount decimal.Decimal, score risk.RiskScore) {
-				_ = s.securityRepo.AddToBlocklist(context.Background(), &domain.BlocklistEntry{
-					Type:      "user",
-					Value:     userID.String(),
-					Reason:    fmt.Sprintf("automatic block due to risk score %d on amount %s", score, amount.String()),
-					AddedBy:   uuid.Nil,
-					ExpiresAt: nil,
-					CreatedAt: time.Now(),
-				})
-			}(req.SenderID, req.Amount, riskScore)
-		}
-
-		return nil, errors.New("transaction blocked by risk engine")
-	}
-
-	// Get receiver's wallet
-	var receiverWallet *domain.Wallet
-
-	if req.ReceiverWalletAddress != "" {
-		// Lookup by Address (Preferred/Strict)
-		receiverWallet, err = s.walletRepo.FindByAddress(ctx, req.ReceiverWalletAddress)
-		if err != nil {
-			return nil, pkgerrors.Wrap(err, "receiver wallet not found by address")
-		}
-		req.ReceiverID = receiverWallet.UserID
-	} else if req.ReceiverID != uuid.Nil {
-		// Lookup by UserID (Fallback for internal calls/simulations)
-		// We need to know which currency wallet to look for.
-		// If DestinationCurrency is set, use that. Otherwise assume same as sender (or default).
-		targetCurrency := req.DestinationCurrency
-		if targetCurrency == "" {
-			targetCurrency = req.Currency
-		}
-
-		receiverWallet, err = s.walletRepo.FindByUserAndCurrency(ctx, req.ReceiverID, targetCurrency)
-		if err != nil {
-			// If not found in target currency, try to find ANY wallet or handle error
-			// For now, fail if not found
-			return nil, pkgerrors.Wrap(err, "receiver wallet not found for user")
-		}
-		req.ReceiverWalletAddress = *receiverWallet.WalletAddress
-	} else {
-		return nil, errors.New("receiver information missing (wallet address or user id required)")
-	}
+	sender := pre.parties.Sender
 
 	// 2. Check if currency conversion needed
 	exchangeRate := decimal.NewFromInt(1)
 	convertedAmount := req.Amount
 	convertedCurrency := req.Currency
+	midRate := decimal.Zero
 
-	if senderWallet.Currency != receiverWallet.Currency {
+	var quote *domain.PaymentQuote
+	if req.QuoteID != uuid.Nil {
+		// Convert at the rate the sender was quoted
+		if quote, err = s.lockedQuote(ctx, req, senderWallet, receiverWallet); err != nil {
+			return nil, err
+		}
+		exchangeRate = quote.ExchangeRate
+		convertedAmount = quote.ConvertedAmount
+		convertedCurrency = receiverWallet.Currency
+		if senderWallet.Currency != receiverWallet.Currency {
+			midRate = quote.MidMarketRate
+		}
+	} else if senderWallet.Currency != receiverWallet.Currency {
 		// Get exchange rate
 		rate, err := s.forexService.GetRate(ctx, senderWallet.Currency, receiverWallet.Currency)
 		if err != nil {
@@ -582,23 +376,40 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 		}
 		// Use sell rate for conversion (sender sells base currency)
 		exchangeRate = rate.SellRate
-		convertedAmount = req.Amount.Mul(rate.SellRate)
+		convertedAmount = req.Amount.Mul(rate.SellRate).Round(receiverWallet.Currency.MinorUnits())
 		convertedCurrency = receiverWallet.Currency
+		midRate = rate.Rate
 	}
 
-	// 3. Calculate fees (1.5% standard fee)
-	feeAmount := req.Amount.Mul(decimal.NewFromFloat(0.015))
+	// 3. Calculate fees by the matching fee schedule, or else at the
+	// sender's plan rate (1.5% standard fee); a quoted payment is charged
+	// the fee it was quoted
+	var fee *domain.FeeBreakdown
+	if quote != nil && quote.Fees != nil {
+		fee = quote.Fees
+	} else {
+		fee = paymentFee(pre.feeSchedules, feeQuery(sender, req.Currency, receiverWallet.Currency, req.Channel, pre.at),
+			req.Amount, req.Currency, pre.entitlements, pre.segments)
+	}
+	feeAmount := fee.Fee
 	totalDebit := req.Amount.Add(feeAmount)
 
-	// 4. Check sender balance
-	if senderWallet.AvailableBalance.LessThan(totalDebit) {
+	// 4. Check sender balance, which a credit facility lets go below zero
+	if senderWallet.AvailableBalance.Add(senderWallet.OverdraftLimit).LessThan(totalDebit) {
 		return nil, pkgerrors.ErrInsufficientBalance
 	}
 
 	// 5. Create transaction record
 	initialStatus := domain.TransactionStatusPending
+	var queuedUntil *time.Time
 	if s.riskEngine.RequiresAdminApproval(req.Amount) {
 		initialStatus = domain.TransactionStatusPendingApproval
+	} else if w, ok := s.corridorWindow(senderWallet.Currency, receiverWallet.Currency); ok {
+		// Past the destination rail's cut-off the payment waits for the next window
+		if next, open := w.NextOpen(time.Now()); !open {
+			initialStatus = domain.TransactionStatusQueued
+			queuedUntil = &next
+		}
 	}
 
 	tx := &domain.Transaction{
@@ -621,15 +432,47 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 		Channel:           req.Channel,
 		Category:          req.Category,
 		Description:       req.Description,
-		Metadata:          req.Metadata,
+		Metadata:          withFeeSchedule(withSettlementLane(req.Metadata, pre.entitlements), fee),
 		InitiatedAt:       time.Now(),
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}
 
+	if queuedUntil != nil {
+		metadata := make(domain.Metadata, len(tx.Metadata)+2)
+		for k, v := range tx.Metadata {
+			metadata[k] = v
+		}
+		metadata[queuedUntilKey] = queuedUntil.Format(time.RFC3339)
+		metadata[queuedCorridorKey] = string(senderWallet.Currency) + "-" + string(receiverWallet.Currency)
+		tx.Metadata = metadata
+	}
+	if midRate.IsPositive() {
+		tx.Metadata = withFXMidRate(tx.Metadata, midRate)
+	}
+	if req.DisclosureID != uuid.Nil {
+		if err := s.holdToDisclosure(ctx, req.DisclosureID, tx); err != nil {
+			return nil, err
+		}
+	}
+	if quote != nil {
+		if err := s.useQuote(ctx, quote, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	// From here on the payment runs as a saga; see saga.go
+	if err := s.beginSaga(ctx, tx, totalDebit); err != nil {
+		s.restoreQuote(ctx, quote)
+		return nil, err
+	}
+
 	// Persist initial transaction record (pending)
 	if err := s.repo.Create(ctx, tx); err != nil {
+		// No payment was made under the quote, so the sender can still use it
+		s.restoreQuote(ctx, quote)
 		if errors.Is(err, pkgerrors.ErrTransactionAlreadyExists) {
+			s.finishSaga(ctx, tx.ID, domain.PaymentSagaCompensated, "reference already used")
 			existingTx, findErr := s.repo.FindByReference(ctx, tx.Reference)
 			if findErr == nil && existingTx != nil {
 				s.logger.Info("Idempotency match found during create", map[string]interface{}{
@@ -661,13 +504,22 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 		})
 		return nil, err
 	}
+	s.sagaBoundary("transaction recorded")
+	s.advanceSaga(ctx, tx.ID, domain.PaymentSagaRecorded)
+
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventInitiated, domain.Metadata{
+		"amount":   tx.Amount.String(),
+		"currency": tx.Currency,
+	})
 
 	// Check if transaction requires admin approval
 	if tx.Status == domain.TransactionStatusPendingApproval {
+		s.recordEvent(ctx, tx.ID, domain.TransactionEventHeldForReview, nil)
 		s.logger.Info("Transaction queued for admin approval", map[string]interface{}{
 			"tx_id":  tx.ID,
 			"amount": tx.Amount.String(),
 		})
+		s.finishSaga(ctx, tx.ID, domain.PaymentSagaCompleted, "held for review")
 
 		// Send notification to admin (simulated) or user
 		go func() {
@@ -683,6 +535,21 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 		}, nil
 	}
 
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventCompliancePassed, nil)
+
+	if tx.Status == domain.TransactionStatusQueued {
+		if err := s.queuePayment(ctx, tx, totalDebit); err != nil {
+			s.finishSaga(ctx, tx.ID, domain.PaymentSagaCompensated, "funds could not be reserved")
+			return nil, err
+		}
+		s.finishSaga(ctx, tx.ID, domain.PaymentSagaCompleted, "queued with funds reserved")
+		return &PaymentResponse{
+			Transaction: tx,
+			Message:     "Payment queued for the next settlement window",
+			QueuedUntil: queuedUntil,
+		}, nil
+	}
+
 	// 6. Process payment atomically
 	if err := s.processPayment(ctx, tx, senderWallet, receiverWallet, totalDebit); err != nil {
 		s.riskEngine.ReportFailure()
@@ -690,6 +557,7 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 		reason := err.Error()
 		tx.StatusReason = reason
 		tx.UpdatedAt = time.Now()
+		s.recordEvent(ctx, tx.ID, domain.TransactionEventFailed, domain.Metadata{"reason": "We could not debit your wallet."})
 		s.logger.Error("Ledger posting failed", map[string]interface{}{
 			"error":              err.Error(),
 			"transaction_id":     tx.ID,
@@ -708,11 +576,17 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 				"error":          updateErr.Error(),
 				"transaction_id": tx.ID,
 			})
+		} else {
+			s.finishSaga(ctx, tx.ID, domain.PaymentSagaCompensated, "ledger posting failed")
 		}
 		return nil, err
 	}
+	s.sagaBoundary("ledger posted")
+	s.advanceSaga(ctx, tx.ID, domain.PaymentSagaPosted)
 
 	s.riskEngine.ReportSuccess()
+	s.recordConversion(ctx, tx)
+	s.meterPayout(ctx, tx, pre.entitlements)
 
 	s.logBlockchainMismatchAsync(tx)
 
@@ -731,6 +605,8 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 		})
 		return nil, err
 	}
+	s.sagaBoundary("transaction settling")
+	s.finishSaga(ctx, tx.ID, domain.PaymentSagaCompleted, "posted; moved on to settlement")
 
 	s.logger.Info("Payment completed", map[string]interface{}{
 		"transaction_id": tx.ID,
@@ -746,16 +622,18 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 	go func() {
 		// Notify Sender
 		_ = s.notifier.Notify(context.Background(), req.SenderID, "PAYMENT_SENT", map[string]interface{}{
-			"amount":        req.Amount.String(),
-			"currency":      req.Currency,
-			"receiver_name": req.ReceiverID.String(), // Ideally name, but ID for now
+			"transaction_id": tx.ID.String(),
+			"amount":         req.Amount.String(),
+			"currency":       req.Currency,
+			"receiver_name":  req.ReceiverID.String(), // Ideally name, but ID for now
 		})
 
 		// Notify Receiver
 		_ = s.notifier.Notify(context.Background(), req.ReceiverID, "PAYMENT_RECEIVED", map[string]interface{}{
-			"amount":      tx.ConvertedAmount.String(),
-			"currency":    tx.ConvertedCurrency,
-			"sender_name": req.SenderID.String(),
+			"transaction_id": tx.ID.String(),
+			"amount":         tx.ConvertedAmount.String(),
+			"currency":       tx.ConvertedCurrency,
+			"sender_name":    req.SenderID.String(),
 		})
 	}()
 
@@ -765,10 +643,303 @@ func (s *Service) InitiatePayment(ctx context.Context, req *InitiatePaymentReque
 	}, nil
 }
 
+// screenPayment runs the checks on a payment that need none of its wallets:
+// the blocklists, the sender's spending controls, the daily limit, cool-off
+// and restricted countries.
+func (s *Service) screenPayment(req *InitiatePaymentRequest, pre *initiation) error {
+	// 0.05 Check Blocklist (Sender)
+	if pre.senderBlocked {
+		s.logger.Warn("Transaction blocked: Sender is blacklisted", map[string]interface{}{"sender_id": req.SenderID})
+		return errors.New("security alert: account is restricted")
+	}
+
+	// 0.06 Check Blocklist (Receiver ID)
+	if pre.receiverBlocked {
+		s.logger.Warn("Transaction blocked: Receiver is blacklisted", map[string]interface{}{"receiver_id": req.ReceiverID})
+		return errors.New("security alert: receiver account is restricted")
+	}
+
+	// 0.07 Check Blocklist (Receiver Wallet Address)
+	if pre.addressBlocked {
+		s.logger.Warn("Transaction blocked: Receiver wallet is blacklisted", map[string]interface{}{"wallet": req.ReceiverWalletAddress})
+		return errors.New("security alert: receiver wallet is restricted")
+	}
+
+	// 0.08 Sender's own spending controls
+	if err := checkSpendingControls(pre.controls, req, pre.dailyTotal); err != nil {
+		s.logger.Warn("Transaction blocked by spending controls", map[string]interface{}{
+			"sender_id": req.SenderID,
+			"reason":    err.Error(),
+		})
+		return err
+	}
+
+	// 0.1 Check Daily Limit
+	dailyTotal := pre.dailyTotal
+	if err := s.riskEngine.CheckDailyLimit(req.Amount, dailyTotal); err != nil {
+		s.logger.Warn("Transaction blocked by daily limit", map[string]interface{}{
+			"amount":      req.Amount.String(),
+			"daily_total": dailyTotal.String(),
+			"sender_id":   req.SenderID,
+		})
+
+		go func() {
+			_ = s.notifier.Notify(context.Background(), req.SenderID, "RISK_ALERT", map[string]interface{}{
+				"reason": "Daily transaction limit exceeded",
+				"limit":  s.riskEngine.GetConfig().MaxDailyLimit,
+			})
+		}()
+
+		// Log Security Event
+		go func() {
+			_ = s.securityRepo.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
+				Type:        "risk_block",
+				Severity:    "high",
+				Description: fmt.Sprintf("Daily limit exceeded. Amount: %s. Total: %s", req.Amount.String(), dailyTotal.String()),
+				Status:      "blocked",
+				UserID:      &req.SenderID,
+				IPAddress:   req.Location,
+				CreatedAt:   time.Now(),
+			})
+		}()
+
+		return err
+	}
+
+	// 0.2 Cool-off Check
+	if err := s.riskEngine.CheckCoolOff(req.SenderID, req.Amount); err != nil {
+		s.logger.Warn("Transaction blocked by cool-off", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	// 0.3 Restricted Country Check
+	if err := s.riskEngine.CheckRestrictedCountry(req.Location); err != nil {
+		s.logger.Warn("Transaction blocked by restricted country", map[string]interface{}{
+			"location":  req.Location,
+			"sender_id": req.SenderID,
+		})
+		return err
+	}
+	return nil
+}
+
+// clearPayment runs the checks on a payment's parties, from the sender's
+// wallet status, KYC limits, velocity and risk to freezes, jurisdictions and
+// the step-up challenge, resolving the receiver's wallet on the way.
+func (s *Service) clearPayment(ctx context.Context, req *InitiatePaymentRequest, pre *initiation) (senderWallet, receiverWallet *domain.Wallet, err error) {
+	dailyTotal := pre.dailyTotal
+	// 1. Get sender and receiver wallets
+	senderWallet = pre.parties.SenderWallet
+	if senderWallet == nil {
+		return nil, nil, pkgerrors.Wrap(pkgerrors.ErrWalletNotFound, "sender wallet not found")
+	}
+	// Suspended wallets, including those frozen for dormancy, cannot pay.
+	if senderWallet.Status == domain.WalletStatusSuspended || senderWallet.Status == domain.WalletStatusClosed {
+		return nil, nil, pkgerrors.Wrap(pkgerrors.ErrWalletNotActive, "sender wallet is "+string(senderWallet.Status))
+	}
+
+	// 1b. Validate Sender KYC Status & Limits
+	sender := pre.parties.Sender
+
+	if sender.KYCStatus != domain.KYCStatusVerified {
+		return nil, nil, errors.New("KYC verification required to send funds")
+	}
+
+	// Define limits based on KYC Level, scaled by the sender's plan
+	limit, dailyLimit := planLimits(sender.KYCLevel, pre.entitlements)
+
+	if sender.KYCLevel == 0 {
+		return nil, nil, errors.New("KYC Level 1 required to transact")
+	}
+
+	if req.Amount.GreaterThan(limit) {
+		return nil, nil, fmt.Errorf("transaction amount exceeds your KYC Level %d limit of %s", sender.KYCLevel, limit.String())
+	}
+
+	// 1c. Check Daily Velocity Limit
+	// dailyTotal is already fetched at the beginning of the function
+
+	if dailyTotal.Add(req.Amount).GreaterThan(dailyLimit) {
+		return nil, nil, fmt.Errorf("daily transaction limit of %s exceeded (used: %s)", dailyLimit.String(), dailyTotal.String())
+	}
+
+	// 1d. Check Hourly Velocity (Fraud Detection)
+	// General Velocity Check
+	hourlyCount := pre.hourlyCount
+	if err := s.riskEngine.CheckVelocity(hourlyCount); err != nil {
+		s.logger.Warn("Transaction blocked by velocity check", map[string]interface{}{
+			"user_id":      req.SenderID,
+			"hourly_count": hourlyCount,
+		})
+		// Log Security Event
+		go func() {
+			_ = s.securityRepo.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
+				Type:        "risk_block",
+				Severity:    "medium",
+				Description: fmt.Sprintf("Velocity limit exceeded. Hourly Count: %d", hourlyCount),
+				Status:      "blocked",
+				UserID:      &req.SenderID,
+				IPAddress:   req.Location,
+				CreatedAt:   time.Now(),
+			})
+		}()
+		return nil, nil, err
+	}
+
+	// Max 3 transactions > HighValueThreshold per hour
+	highValueThreshold := decimal.NewFromInt(s.riskEngine.GetConfig().HighValueThreshold)
+	if req.Amount.GreaterThan(highValueThreshold) {
+		count, err := s.repo.GetHourlyHighValueCount(ctx, req.SenderID, highValueThreshold)
+		if err != nil {
+			return nil, nil, pkgerrors.Wrap(err, "failed to check hourly velocity")
+		}
+		if count >= highValuePerHour {
+			return nil, nil, errors.New("velocity limit exceeded: too many high-value transactions in the last hour")
+		}
+	}
+
+	// 1e. Advanced Risk Analysis & Cool-off
+	if err := s.riskEngine.CheckCoolOff(req.SenderID, req.Amount); err != nil {
+		return nil, nil, err
+	}
+
+	// 1f. Behavioral Anomaly Detection
+	anomalies, err := s.monitor.DetectAnomalies(req.SenderID, req.Amount, req.ReceiverID.String())
+	if err == nil && len(anomalies) > 0 {
+		for _, anomaly := range anomalies {
+			s.logger.Warn("Behavioral anomaly detected", map[string]interface{}{
+				"user_id":     req.SenderID,
+				"type":        anomaly.Type,
+				"description": anomaly.Description,
+				"severity":    anomaly.Severity,
+			})
+
+			// HIGH and CRITICAL severity are blocked outright; neither
+			// step-up nor a trusted beneficiary lets them through
+			if blocksPayment(anomaly) {
+				// Notify user
+				go func() {
+					_ = s.notifier.Notify(context.Background(), req.SenderID, "SECURITY_ALERT", map[string]interface{}{
+						"reason": anomaly.Description,
+					})
+				}()
+				return nil, nil, fmt.Errorf("security alert: %s", anomaly.Description)
+			}
+		}
+	}
+
+	accountAgeDays := int(time.Since(sender.CreatedAt).Hours() / 24)
+	if accountAgeDays < 0 {
+		accountAgeDays = 0
+	}
+	riskScore := adjustRisk(s.riskEngine.EvaluateRisk(req.Amount, sender.KYCLevel, false, req.Location, accountAgeDays), pre.segments)
+	if riskScore >= risk.RiskScoreCritical {
+		s.logger.Error("Transaction blocked due to CRITICAL risk score", map[string]interface{}{
+			"risk_score": riskScore,
+			"amount":     req.Amount.String(),
+			"sender_id":  req.SenderID,
+		})
+
+		go func() {
+			_ = s.notifier.Notify(context.Background(), req.SenderID, "RISK_ALERT", map[string]interface{}{
+				"reason": "Transaction blocked due to high risk score",
+				"amount": req.Amount.String(),
+			})
+		}()
+
+		go func() {
+			_ = s.securityRepo.LogSecurityEvent(context.Background(), &domain.SecurityEvent{
+				Type:        "risk_block",
+				Severity:    "critical",
+				Description: fmt.Sprintf("Transaction blocked. Risk Score: %d. Amount: %s", riskScore, req.Amount.String()),
+				Status:      "blocked",
+				UserID:      &req.SenderID,
+				IPAddress:   req.Location,
+				CreatedAt:   time.Now(),
+			})
+		}()
+
+		if s.securityRepo != nil {
+			go func(userID uuid.UUID, amount decimal.Decimal, score risk.RiskScore) {
+				_ = s.securityRepo.AddToBlocklist(context.Background(), &domain.BlocklistEntry{
+					Type:      "user",
+					Value:     userID.String(),
+					Reason:    fmt.Sprintf("automatic block due to risk score %d on amount %s", score, amount.String()),
+					AddedBy:   uuid.Nil,
+					ExpiresAt: nil,
+					CreatedAt: time.Now(),
+				})
+			}(req.SenderID, req.Amount, riskScore)
+		}
+
+		return nil, nil, errors.New("transaction blocked by risk engine")
+	}
+
+	// Get receiver's wallet
+	receiverWallet = pre.parties.ReceiverWallet
+
+	if req.ReceiverWalletAddress != "" {
+		// Lookup by Address (Preferred/Strict). Derived digital wallet
+		// numbers are only resolved by FindByAddress.
+		if receiverWallet == nil {
+			receiverWallet, err = s.walletRepo.FindByAddress(ctx, req.ReceiverWalletAddress)
+			if err != nil {
+				return nil, nil, pkgerrors.Wrap(err, "receiver wallet not found by address")
+			}
+		}
+		req.ReceiverID = receiverWallet.UserID
+	} else if req.ReceiverID != uuid.Nil {
+		// Lookup by UserID (Fallback for internal calls/simulations), in
+		// DestinationCurrency or else the sending currency.
+		if receiverWallet == nil {
+			return nil, nil, pkgerrors.Wrap(pkgerrors.ErrWalletNotFound, "receiver wallet not found for user")
+		}
+		req.ReceiverWalletAddress = *receiverWallet.WalletAddress
+	} else {
+		return nil, nil, errors.New("receiver information missing (wallet address or user id required)")
+	}
+
+	if err := checkCrossBorder(pre.controls, senderWallet, receiverWallet); err != nil {
+		s.logger.Warn("Transaction blocked by spending controls", map[string]interface{}{
+			"sender_id": req.SenderID,
+			"reason":    err.Error(),
+		})
+		return nil, nil, err
+	}
+
+	// Emergency money movement freezes
+	if err := s.checkFreeze(ctx, senderWallet.Currency, receiverWallet.Currency); err != nil {
+		s.logger.Warn("Payment blocked by money movement freeze", map[string]interface{}{
+			"sender_id": req.SenderID,
+			"reason":    err.Error(),
+		})
+		return nil, nil, err
+	}
+
+	// Embargoes and other restricted jurisdictions
+	if err := s.checkJurisdictions(ctx, req, pre.parties, senderWallet, receiverWallet); err != nil {
+		s.logger.Warn("Payment blocked by restricted jurisdiction", map[string]interface{}{
+			"sender_id": req.SenderID,
+			"reason":    err.Error(),
+		})
+		return nil, nil, err
+	}
+
+	// 1g. Step-up challenge, skipped for small payments to trusted beneficiaries
+	if err := s.challengePayment(ctx, req, receiverWallet); err != nil {
+		return nil, nil, err
+	}
+	return senderWallet, receiverWallet, nil
+}
+
 type Receipt struct {
 	TransactionID uuid.UUID       `json:"transaction_id"`
 	Reference     string          `json:"reference"`
+	Number        string          `json:"reference_number,omitempty"`
 	Date          time.Time       `json:"date"`
+	DateLocal     clock.Stamp     `json:"date_local"` // Date in the viewer's time zone
 	SenderName    string          `json:"sender_name"`
 	ReceiverName  string          `json:"receiver_name"`
 	Amount        decimal.Decimal `json:"amount"`
@@ -820,10 +991,17 @@ func (s *Service) GetReceipt(ctx context.Context, txID uuid.UUID, userID uuid.UU
 		return nil, errors.New("failed to fetch receiver details")
 	}
 
+	viewer := sender
+	if userID != tx.SenderID {
+		viewer = receiver
+	}
+
 	return &Receipt{
 		TransactionID: tx.ID,
 		Reference:     tx.Reference,
+		Number:        tx.ReferenceNumber,
 		Date:          tx.CreatedAt,
+		DateLocal:     clock.In(tx.CreatedAt, viewer.TimeZone),
 		SenderName:    fmt.Sprintf("%s %s", sender.FirstName, sender.LastName),
 		ReceiverName:  fmt.Sprintf("%s %s", receiver.FirstName, receiver.LastName),
 		Amount:        tx.Amount,
@@ -858,10 +1036,36 @@ func (s *Service) processPayment(
 		Currency:          tx.Currency,
 		ConvertedCurrency: tx.ConvertedCurrency,
 		ExchangeRate:      tx.ExchangeRate,
+		MidRate:           fxMidRate(tx),
 		FeeAmount:         tx.FeeAmount,
+		IdempotencyKey:    paymentPostingKey(tx.ID),
+		AllowOverdraft:    true,
 	})
 }
 
+// fxMidRateKey records the market mid rate a cross-currency payment was
+// priced against, so the ledger can book the spread as FX income.
+const fxMidRateKey = "fx_mid_rate"
+
+func withFXMidRate(metadata domain.Metadata, rate decimal.Decimal) domain.Metadata {
+	out := make(domain.Metadata, len(metadata)+1)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	out[fxMidRateKey] = rate.String()
+	return out
+}
+
+// fxMidRate is the mid rate recorded on tx, or zero when it has none.
+func fxMidRate(tx *domain.Transaction) decimal.Decimal {
+	v, _ := tx.Metadata[fxMidRateKey].(string)
+	rate, err := decimal.NewFromString(v)
+	if err != nil {
+		return decimal.Zero
+	}
+	return rate
+}
+
 func (s *Service) getReceiverWallet(ctx context.Context, userID uuid.UUID, currency, destinationCurrency domain.Currency) (*domain.Wallet, error) {
 	// Optimization: Fetch all wallets for the user in one go to reduce DB round trips
 	wallets, err := s.walletRepo.FindByUserID(ctx, userID)
@@ -926,7 +1130,28 @@ func (s *Service) GetTransaction(ctx context.Context, id uuid.UUID) (*Transactio
 	if err != nil {
 		return nil, err
 	}
+	return s.transactionDetail(ctx, tx), nil
+}
+
+// GetTransactionByReference finds a transaction by the reference number on
+// its receipt, however it was typed. Input that is not a reference number is
+// matched exactly against the client-supplied reference instead.
+func (s *Service) GetTransactionByReference(ctx context.Context, ref string) (*TransactionDetail, error) {
+	var tx *domain.Transaction
+	var err error
+	if number, parseErr := reference.Parse(ref); parseErr == nil {
+		tx, err = s.repo.FindByReferenceNumber(ctx, number)
+	} else {
+		tx, err = s.repo.FindByReference(ctx, strings.TrimSpace(ref))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.transactionDetail(ctx, tx), nil
+}
 
+// transactionDetail enriches tx with party names and wallet numbers.
+func (s *Service) transactionDetail(ctx context.Context, tx *domain.Transaction) *TransactionDetail {
 	detail := &TransactionDetail{Transaction: tx}
 
 	// Enrich with Names
@@ -949,7 +1174,7 @@ func (s *Service) GetTransaction(ctx context.Context, id uuid.UUID) (*Transactio
 		}
 	}
 
-	return detail, nil
+	return detail
 }
 
 func (s *Service) FlagTransaction(ctx context.Context, id uuid.UUID, reason string) error {
@@ -990,6 +1215,41 @@ func (s *Service) GetUserTransactions(ctx context.Context, userID uuid.UUID, wal
 		}
 	}
 
+	details := s.userTransactionDetails(ctx, txs)
+	s.attachUserNotes(ctx, userID, details)
+	return details, total, nil
+}
+
+// GetUserTransactionsAfter returns the next page of the user's transactions
+// older than the cursor, newest first, and the cursor of the following page,
+// which is nil once the history is exhausted. Unlike offset paging it costs
+// the same on every page and does not skip or repeat rows when new payments
+// arrive between requests.
+func (s *Service) GetUserTransactionsAfter(ctx context.Context, userID uuid.UUID, after *domain.TransactionCursor, limit int) ([]*TransactionDetail, *domain.TransactionCursor, error) {
+	txs, err := s.repo.FindByUserIDAfter(ctx, userID, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	var next *domain.TransactionCursor
+	if len(txs) == limit && limit > 0 {
+		next = domain.CursorAfter(txs[len(txs)-1])
+	}
+	details := s.userTransactionDetails(ctx, txs)
+	s.attachUserNotes(ctx, userID, details)
+	return details, next, nil
+}
+
+// GetUserPendingTransactions returns up to limit of the payments the user
+// sent that are still waiting to be approved, newest first.
+func (s *Service) GetUserPendingTransactions(ctx context.Context, userID uuid.UUID, limit int) ([]*TransactionDetail, error) {
+	txs, err := s.repo.FindPendingBySender(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.userTransactionDetails(ctx, txs), nil
+}
+
+func (s *Service) userTransactionDetails(ctx context.Context, txs []*domain.Transaction) []*TransactionDetail {
 	var details []*TransactionDetail
 	for _, tx := range txs {
 		detail := &TransactionDetail{Transaction: tx}
@@ -1029,7 +1289,7 @@ func (s *Service) GetUserTransactions(ctx context.Context, userID uuid.UUID, wal
 		details = append(details, detail)
 	}
 
-	return details, total, nil
+	return details
 }
 
 func (s *Service) GetAllTransactions(ctx context.Context, limit, offset int) ([]*TransactionDetail, int, error) {
@@ -1149,7 +1409,10 @@ type Repository interface {
 	Flag(ctx context.Context, id uuid.UUID, reason string) error
 	FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
 	FindByReference(ctx context.Context, ref string) (*domain.Transaction, error)
+	FindByReferenceNumber(ctx context.Context, ref string) (*domain.Transaction, error)
 	FindByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, error)
+	FindByUserIDAfter(ctx context.Context, userID uuid.UUID, after *domain.TransactionCursor, limit int) ([]*domain.Transaction, error)
+	FindPendingBySender(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Transaction, error)
 	CountByUserID(ctx context.Context, userID uuid.UUID) (int, error)
 	FindByWalletID(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*domain.Transaction, error)
 	CountByWalletID(ctx context.Context, walletID uuid.UUID) (int, error)
@@ -1195,9 +1458,12 @@ type WalletRepository interface {
 	FindByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Wallet, error)
 	FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error)
 	FindByAddress(ctx context.Context, address string) (*domain.Wallet, error)
+	FindPaymentParties(ctx context.Context, q domain.PaymentPartiesQuery) (*domain.PaymentParties, error)
 	DebitWallet(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error
 	CreditWallet(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error
 	ReserveFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error
+	ReleaseReservedFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error
+	DebitReservedFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error
 }
 
 type SecurityRepository interface {
@@ -1231,16 +1497,25 @@ func (s *Service) CancelTransaction(ctx context.Context, txID, userID uuid.UUID)
 		return errors.New("unauthorized to cancel this transaction")
 	}
 
+	// Queued payments hand their reserved funds back
+	if tx.Status == domain.TransactionStatusQueued && s.queue != nil {
+		return s.cancelQueued(ctx, tx)
+	}
+
 	// Only pending transactions can be cancelled
 	if tx.Status != domain.TransactionStatusPending {
-		return errors.New("only pending transactions can be cancelled")
+		return errors.New("only pending or queued transactions can be cancelled")
 	}
 
 	tx.Status = domain.TransactionStatusCancelled
 	now := time.Now()
 	tx.CompletedAt = &now
 
-	return s.repo.Update(ctx, tx)
+	if err := s.repo.Update(ctx, tx); err != nil {
+		return err
+	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventCancelled, domain.Metadata{"reason": "You cancelled this payment."})
+	return nil
 }
 
 type BulkPaymentRequest struct {
@@ -1336,6 +1611,11 @@ func (s *Service) ReviewTransaction(ctx context.Context, txID uuid.UUID, adminID
 	if tx.Status != domain.TransactionStatusPendingApproval {
 		return errors.New("transaction is not pending approval")
 	}
+	// Withdrawals hold reserved funds for a payout provider; they are
+	// reviewed through the funding service.
+	if tx.TransactionType == domain.TransactionTypeWithdrawal {
+		return errors.New("withdrawals are reviewed through /admin/withdrawals")
+	}
 
 	if action == "approve" {
 		// Proceed with payment processing
@@ -1357,11 +1637,15 @@ func (s *Service) ReviewTransaction(ctx context.Context, txID uuid.UUID, adminID
 		// Calculate Debit Amount (Original Amount + Fee)
 		totalDebit := tx.Amount.Add(tx.FeeAmount)
 
+		s.recordEvent(ctx, tx.ID, domain.TransactionEventCompliancePassed, domain.Metadata{"reviewed": true})
+
 		// Process payment atomically
 		if err := s.processPayment(ctx, tx, senderWallet, receiverWallet, totalDebit); err != nil {
 			s.logger.Error("Admin approval failed at ledger", map[string]interface{}{"error": err.Error()})
 			return err
 		}
+		s.recordConversion(ctx, tx)
+		s.meterPayout(ctx, tx, nil)
 
 		// Update Status
 		tx.Status = domain.TransactionStatusPendingSettlement
@@ -1399,6 +1683,7 @@ func (s *Service) ReviewTransaction(ctx context.Context, txID uuid.UUID, adminID
 		if err := s.repo.Update(ctx, tx); err != nil {
 			return err
 		}
+		s.recordEvent(ctx, tx.ID, domain.TransactionEventFailed, domain.Metadata{"reason": "Your payment did not pass compliance review."})
 
 		// Notify
 		go func() {
@@ -1495,6 +1780,7 @@ func (s *Service) ReverseTransactionAdmin(ctx context.Context, txID uuid.UUID, a
 		FeeAmount:         decimal.Zero,
 		EventType:         "admin_reversal",
 		Description:       fmt.Sprintf("Admin reversal for %s", tx.Reference),
+		IdempotencyKey:    "admin_reversal:" + tx.ID.String(),
 	}
 	if err := s.ledgerService.PostTransaction(ctx, reversalPosting); err != nil {
 		return fmt.Errorf("failed to reverse funds: %w", err)
@@ -1516,6 +1802,7 @@ func (s *Service) ReverseTransactionAdmin(ctx context.Context, txID uuid.UUID, a
 				FeeAmount:         decimal.Zero,
 				EventType:         "fee_refund",
 				Description:       fmt.Sprintf("Fee refund for reversal %s", tx.Reference),
+				IdempotencyKey:    "fee_refund:" + tx.ID.String(),
 			}
 			_ = s.ledgerService.PostTransaction(ctx, feeRefund)
 		}
@@ -1535,6 +1822,7 @@ func (s *Service) ReverseTransactionAdmin(ctx context.Context, txID uuid.UUID, a
 	if err := s.repo.Update(ctx, tx); err != nil {
 		return err
 	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventReversed, domain.Metadata{"reason": "The funds were returned to your wallet."})
 
 	// Create a reversal transaction record for visibility (best-effort).
 	revTx := &domain.Transaction{
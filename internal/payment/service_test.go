@@ -3,17 +3,20 @@ package payment
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"kyd/internal/domain"
 	"kyd/internal/ledger"
 	"kyd/internal/notification"
+	"kyd/pkg/reference"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // --- Mocks ---
@@ -53,6 +56,14 @@ func (m *MockRepository) FindByReference(ctx context.Context, ref string) (*doma
 	return args.Get(0).(*domain.Transaction), args.Error(1)
 }
 
+func (m *MockRepository) FindByReferenceNumber(ctx context.Context, ref string) (*domain.Transaction, error) {
+	args := m.Called(ctx, ref)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Transaction), args.Error(1)
+}
+
 func (m *MockRepository) FindByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, error) {
 	args := m.Called(ctx, userID, limit, offset)
 	if args.Get(0) == nil {
@@ -61,6 +72,22 @@ func (m *MockRepository) FindByUserID(ctx context.Context, userID uuid.UUID, lim
 	return args.Get(0).([]*domain.Transaction), args.Error(1)
 }
 
+func (m *MockRepository) FindByUserIDAfter(ctx context.Context, userID uuid.UUID, after *domain.TransactionCursor, limit int) ([]*domain.Transaction, error) {
+	args := m.Called(ctx, userID, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Transaction), args.Error(1)
+}
+
+func (m *MockRepository) FindPendingBySender(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Transaction, error) {
+	args := m.Called(ctx, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Transaction), args.Error(1)
+}
+
 func (m *MockRepository) CountByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
 	args := m.Called(ctx, userID)
 	return args.Int(0), args.Error(1)
@@ -213,6 +240,14 @@ func (m *MockWalletRepository) FindByAddress(ctx context.Context, address string
 	return args.Get(0).(*domain.Wallet), args.Error(1)
 }
 
+func (m *MockWalletRepository) FindPaymentParties(ctx context.Context, q domain.PaymentPartiesQuery) (*domain.PaymentParties, error) {
+	args := m.Called(ctx, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PaymentParties), args.Error(1)
+}
+
 func (m *MockWalletRepository) DebitWallet(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error {
 	args := m.Called(ctx, walletID, amount)
 	return args.Error(0)
@@ -228,6 +263,16 @@ func (m *MockWalletRepository) ReserveFunds(ctx context.Context, walletID uuid.U
 	return args.Error(0)
 }
 
+func (m *MockWalletRepository) ReleaseReservedFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error {
+	args := m.Called(ctx, walletID, amount)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) DebitReservedFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error {
+	args := m.Called(ctx, walletID, amount)
+	return args.Error(0)
+}
+
 type MockForexService struct {
 	mock.Mock
 }
@@ -398,7 +443,6 @@ func TestInitiatePayment_FeeCalculation(t *testing.T) {
 		KYCStatus: domain.KYCStatusVerified,
 		KYCLevel:  3,
 	}
-	mockUserRepo.On("FindByID", ctx, senderID).Return(senderUser, nil)
 
 	// Mock Sender Wallet
 	senderWallet := &domain.Wallet{
@@ -408,7 +452,6 @@ func TestInitiatePayment_FeeCalculation(t *testing.T) {
 		AvailableBalance: decimal.NewFromInt(2000), // Enough for amount + fee
 		Status:           domain.WalletStatusActive,
 	}
-	mockWalletRepo.On("FindByUserAndCurrency", ctx, senderID, currency).Return(senderWallet, nil)
 
 	// Mock Receiver Wallet
 	receiverWallet := &domain.Wallet{
@@ -417,7 +460,15 @@ func TestInitiatePayment_FeeCalculation(t *testing.T) {
 		Currency: currency,
 		Status:   domain.WalletStatusActive,
 	}
-	mockWalletRepo.On("FindByAddress", ctx, "1234567890123456").Return(receiverWallet, nil)
+
+	// Sender and both wallets are loaded in one query
+	mockWalletRepo.On("FindPaymentParties", ctx, domain.PaymentPartiesQuery{
+		SenderID:         senderID,
+		Currency:         currency,
+		ReceiverAddress:  "1234567890123456",
+		ReceiverID:       receiverID,
+		ReceiverCurrency: currency,
+	}).Return(&domain.PaymentParties{Sender: senderUser, SenderWallet: senderWallet, ReceiverWallet: receiverWallet}, nil)
 
 	// Mock Exchange Rate (Same currency)
 	rate := &domain.ExchangeRate{
@@ -525,3 +576,29 @@ func TestGetReceipt_Success(t *testing.T) {
 	assert.Equal(t, "15", receipt.Fee.String())
 	assert.Equal(t, "1015", receipt.TotalDebited.String())
 }
+
+func TestGetTransactionByReference(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	service := NewService(mockRepo, new(MockWalletRepository), new(MockForexService), new(MockLedgerService), mockUserRepo, new(MockNotificationService), new(MockAuditRepository), new(MockSecurityRepository), new(MockLogger), nil)
+
+	ctx := context.Background()
+	number, err := reference.New()
+	require.NoError(t, err)
+	tx := &domain.Transaction{ID: uuid.New(), SenderID: uuid.New(), ReceiverID: uuid.New(), ReferenceNumber: number}
+	legacy := &domain.Transaction{ID: uuid.New(), SenderID: tx.SenderID, ReceiverID: tx.ReceiverID, Reference: "client-ref-1"}
+
+	mockRepo.On("FindByReferenceNumber", ctx, number).Return(tx, nil)
+	mockRepo.On("FindByReference", ctx, "client-ref-1").Return(legacy, nil)
+	mockUserRepo.On("FindByID", ctx, mock.Anything).Return(&domain.User{FirstName: "Jane", LastName: "Doe"}, nil)
+
+	// A reference number read back over the phone, lower case and unspaced.
+	got, err := service.GetTransactionByReference(ctx, strings.ToLower(strings.ReplaceAll(number, "-", "")))
+	require.NoError(t, err)
+	assert.Equal(t, tx.ID, got.ID)
+	assert.Equal(t, "Jane Doe", got.SenderName)
+
+	got, err = service.GetTransactionByReference(ctx, " client-ref-1 ")
+	require.NoError(t, err)
+	assert.Equal(t, legacy.ID, got.ID)
+}
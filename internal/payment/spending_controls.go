@@ -0,0 +1,186 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const maxBlockedChannels = 10
+
+var (
+	// ErrInvalidSpendingControls is returned for controls outside the limits.
+	ErrInvalidSpendingControls = errors.New("invalid spending controls")
+	// ErrSpendingControlsDisabled is returned when no spending control
+	// repository is configured.
+	ErrSpendingControlsDisabled = errors.New("spending controls are not enabled")
+	// ErrBlockedBySpendingControls is returned when a payment breaks one of
+	// the sender's own spending controls.
+	ErrBlockedBySpendingControls = errors.New("payment blocked by your spending controls")
+)
+
+// SpendingControlRepository persists customers' spending controls.
+type SpendingControlRepository interface {
+	// Get returns the user's controls, or nil when they have set none.
+	Get(ctx context.Context, userID uuid.UUID) (*domain.SpendingControls, error)
+	Upsert(ctx context.Context, c *domain.SpendingControls) error
+}
+
+// WithSpendingControls lets customers set limits on their own payments.
+// Controls are read on every initiation, so changes apply immediately.
+func (s *Service) WithSpendingControls(repo SpendingControlRepository) *Service {
+	s.controls = repo
+	return s
+}
+
+// GetSpendingControls returns the customer's controls; a customer who has
+// set none gets an empty set.
+func (s *Service) GetSpendingControls(ctx context.Context, userID uuid.UUID) (*domain.SpendingControls, error) {
+	if s.controls == nil {
+		return nil, ErrSpendingControlsDisabled
+	}
+	c, err := s.controls.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = &domain.SpendingControls{UserID: userID, BlockedChannels: []string{}}
+	}
+	return c, nil
+}
+
+// UpdateSpendingControls replaces the customer's controls and notifies them
+// of what changed, so an unexpected change is noticed.
+func (s *Service) UpdateSpendingControls(ctx context.Context, userID uuid.UUID, c domain.SpendingControls) (*domain.SpendingControls, error) {
+	if s.controls == nil {
+		return nil, ErrSpendingControlsDisabled
+	}
+	for _, limit := range []*decimal.Decimal{c.MaxPerTransaction, c.DailyCap} {
+		if limit != nil && !limit.IsPositive() {
+			return nil, fmt.Errorf("%w: limits must be greater than zero", ErrInvalidSpendingControls)
+		}
+	}
+	channels, err := normalizeChannels(c.BlockedChannels)
+	if err != nil {
+		return nil, err
+	}
+	old, err := s.GetSpendingControls(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.UserID = userID
+	c.BlockedChannels = channels
+	c.UpdatedAt = time.Now().UTC()
+	if err := s.controls.Upsert(ctx, &c); err != nil {
+		return nil, err
+	}
+
+	changed := spendingControlChanges(old, &c)
+	if len(changed) > 0 {
+		s.logger.Info("Spending controls changed", map[string]interface{}{"user_id": userID, "changed": changed})
+		if s.notifier != nil {
+			go func() {
+				_ = s.notifier.Notify(context.Background(), userID, "SPENDING_CONTROLS_CHANGED", map[string]interface{}{
+					"changed": changed,
+					"frozen":  c.Frozen,
+				})
+			}()
+		}
+	}
+	return &c, nil
+}
+
+// checkSpendingControls applies the sender's controls that do not depend on
+// the receiver. dailyTotal is what the sender has sent in req.Currency in
+// the last 24 hours.
+func checkSpendingControls(c *domain.SpendingControls, req *InitiatePaymentRequest, dailyTotal decimal.Decimal) error {
+	if c == nil {
+		return nil
+	}
+	if c.Frozen {
+		return fmt.Errorf("%w: outgoing payments are frozen", ErrBlockedBySpendingControls)
+	}
+	// The channel is client-supplied, so a payment that does not name one
+	// cannot be shown to avoid the blocked ones.
+	channel := normalizeChannel(req.Channel)
+	if channel == "" && len(c.BlockedChannels) > 0 {
+		return fmt.Errorf("%w: payments must name their channel while channels are blocked", ErrBlockedBySpendingControls)
+	}
+	for _, blocked := range c.BlockedChannels {
+		if channel == blocked {
+			return fmt.Errorf("%w: payments via %s are blocked", ErrBlockedBySpendingControls, channel)
+		}
+	}
+	if c.MaxPerTransaction != nil && req.Amount.GreaterThan(*c.MaxPerTransaction) {
+		return fmt.Errorf("%w: amount exceeds your per-transaction maximum of %s", ErrBlockedBySpendingControls, c.MaxPerTransaction.String())
+	}
+	if c.DailyCap != nil && dailyTotal.Add(req.Amount).GreaterThan(*c.DailyCap) {
+		return fmt.Errorf("%w: amount exceeds your daily cap of %s", ErrBlockedBySpendingControls, c.DailyCap.String())
+	}
+	return nil
+}
+
+// checkCrossBorder blocks payments that leave the sender's currency when the
+// sender has blocked cross-border payments. Wallets are held in their
+// country's currency, so a conversion is what makes a payment cross-border.
+func checkCrossBorder(c *domain.SpendingControls, sender, receiver *domain.Wallet) error {
+	if c == nil || !c.BlockCrossBorder || sender.Currency == receiver.Currency {
+		return nil
+	}
+	return fmt.Errorf("%w: cross-border payments are blocked", ErrBlockedBySpendingControls)
+}
+
+// spendingControlChanges names the controls that differ between old and c.
+func spendingControlChanges(old, c *domain.SpendingControls) []string {
+	var changed []string
+	sameLimit := func(a, b *decimal.Decimal) bool {
+		return (a == nil) == (b == nil) && (a == nil || a.Equal(*b))
+	}
+	if !sameLimit(old.MaxPerTransaction, c.MaxPerTransaction) {
+		changed = append(changed, "max_per_transaction")
+	}
+	if !sameLimit(old.DailyCap, c.DailyCap) {
+		changed = append(changed, "daily_cap")
+	}
+	if old.BlockCrossBorder != c.BlockCrossBorder {
+		changed = append(changed, "block_cross_border")
+	}
+	if strings.Join(old.BlockedChannels, ",") != strings.Join(c.BlockedChannels, ",") {
+		changed = append(changed, "blocked_channels")
+	}
+	if old.Frozen != c.Frozen {
+		changed = append(changed, "frozen")
+	}
+	return changed
+}
+
+// normalizeChannels lower-cases, trims and de-duplicates channels, keeping
+// their order.
+func normalizeChannels(channels []string) ([]string, error) {
+	out := make([]string, 0, len(channels))
+	seen := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		ch = normalizeChannel(ch)
+		if ch == "" || seen[ch] {
+			continue
+		}
+		seen[ch] = true
+		out = append(out, ch)
+	}
+	if len(out) > maxBlockedChannels {
+		return nil, fmt.Errorf("%w: at most %d blocked channels", ErrInvalidSpendingControls, maxBlockedChannels)
+	}
+	return out, nil
+}
+
+func normalizeChannel(ch string) string {
+	return strings.ToLower(strings.TrimSpace(ch))
+}
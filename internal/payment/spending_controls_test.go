@@ -0,0 +1,128 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memControls map[uuid.UUID]domain.SpendingControls
+
+func (m memControls) Get(ctx context.Context, userID uuid.UUID) (*domain.SpendingControls, error) {
+	c, ok := m[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (m memControls) Upsert(ctx context.Context, c *domain.SpendingControls) error {
+	m[c.UserID] = *c
+	return nil
+}
+
+type notifyRecorder struct {
+	notification.Service
+	events chan string
+}
+
+func (n *notifyRecorder) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
+	n.events <- eventType
+	return nil
+}
+
+func dec(v int64) *decimal.Decimal {
+	d := decimal.NewFromInt(v)
+	return &d
+}
+
+func TestUpdateSpendingControls(t *testing.T) {
+	ctx := context.Background()
+	notifier := &notifyRecorder{events: make(chan string, 1)}
+	s := NewService(nil, nil, nil, nil, nil, notifier, nil, nil, logger.NewNop(), nil).
+		WithSpendingControls(memControls{})
+	user := uuid.New()
+
+	c, err := s.GetSpendingControls(ctx, user)
+	require.NoError(t, err)
+	assert.False(t, c.Frozen)
+	assert.Empty(t, c.BlockedChannels, "a customer without controls gets an empty set")
+
+	_, err = s.UpdateSpendingControls(ctx, user, domain.SpendingControls{DailyCap: dec(0)})
+	assert.ErrorIs(t, err, ErrInvalidSpendingControls)
+
+	c, err = s.UpdateSpendingControls(ctx, user, domain.SpendingControls{
+		MaxPerTransaction: dec(500),
+		BlockedChannels:   []string{" USSD", "ussd", ""},
+		Frozen:            true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ussd"}, []string(c.BlockedChannels))
+	select {
+	case ev := <-notifier.events:
+		assert.Equal(t, "SPENDING_CONTROLS_CHANGED", ev)
+	case <-time.After(time.Second):
+		t.Fatal("no change notification")
+	}
+
+	got, err := s.GetSpendingControls(ctx, user)
+	require.NoError(t, err)
+	assert.True(t, got.Frozen)
+	assert.True(t, got.MaxPerTransaction.Equal(decimal.NewFromInt(500)))
+
+	_, err = s.UpdateSpendingControls(ctx, user, *got)
+	require.NoError(t, err)
+	select {
+	case <-notifier.events:
+		t.Fatal("saving unchanged controls should not notify")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCheckSpendingControls(t *testing.T) {
+	req := &InitiatePaymentRequest{Amount: decimal.NewFromInt(300), Channel: "Mobile"}
+	daily := decimal.NewFromInt(800)
+
+	assert.NoError(t, checkSpendingControls(nil, req, daily))
+	assert.NoError(t, checkSpendingControls(&domain.SpendingControls{MaxPerTransaction: dec(300), DailyCap: dec(1100)}, req, daily))
+
+	for name, c := range map[string]*domain.SpendingControls{
+		"frozen":          {Frozen: true},
+		"blocked channel": {BlockedChannels: []string{"mobile"}},
+		"per transaction": {MaxPerTransaction: dec(299)},
+		"daily cap":       {DailyCap: dec(1099)},
+	} {
+		assert.ErrorIs(t, checkSpendingControls(c, req, daily), ErrBlockedBySpendingControls, name)
+	}
+}
+
+func TestCheckSpendingControls_UnnamedChannelIsBlocked(t *testing.T) {
+	c := &domain.SpendingControls{BlockedChannels: []string{"ussd"}}
+	daily := decimal.Zero
+
+	for _, channel := range []string{"", "   "} {
+		req := &InitiatePaymentRequest{Amount: decimal.NewFromInt(10), Channel: channel}
+		assert.ErrorIs(t, checkSpendingControls(c, req, daily), ErrBlockedBySpendingControls, "channel %q", channel)
+		assert.NoError(t, checkSpendingControls(&domain.SpendingControls{}, req, daily), "channel %q", channel)
+	}
+	assert.NoError(t, checkSpendingControls(c, &InitiatePaymentRequest{Amount: decimal.NewFromInt(10), Channel: "mobile"}, daily))
+}
+
+func TestCheckCrossBorder(t *testing.T) {
+	mwk := &domain.Wallet{Currency: domain.MWK}
+	cny := &domain.Wallet{Currency: domain.CNY}
+	block := &domain.SpendingControls{BlockCrossBorder: true}
+
+	assert.NoError(t, checkCrossBorder(block, mwk, mwk))
+	assert.NoError(t, checkCrossBorder(&domain.SpendingControls{}, mwk, cny))
+	assert.ErrorIs(t, checkCrossBorder(block, mwk, cny), ErrBlockedBySpendingControls)
+}
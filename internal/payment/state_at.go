@@ -0,0 +1,225 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// accountStateSettle is how far in the past a moment must be before its
+// state is cached: payments still being written can change a later one.
+const accountStateSettle = 5 * time.Minute
+
+var (
+	// ErrInvalidStateTime is returned for a moment an account's state
+	// cannot be rebuilt at, such as one in the future.
+	ErrInvalidStateTime = errors.New("invalid account state time")
+	// ErrAccountStateDisabled is returned when balance history is not
+	// configured.
+	ErrAccountStateDisabled = errors.New("account state history is not enabled")
+)
+
+// BalanceHistory keeps wallet balance snapshots and rebuilds balances and
+// limit usage at past moments.
+type BalanceHistory interface {
+	TakeBalanceSnapshots(ctx context.Context, periodStart time.Time) (int, error)
+	PruneBalanceSnapshots(ctx context.Context, before time.Time) (int, error)
+	WalletBalancesAt(ctx context.Context, userID uuid.UUID, at time.Time) ([]domain.WalletBalanceAt, error)
+	DailyTotalAt(ctx context.Context, userID uuid.UUID, currency domain.Currency, at time.Time) (decimal.Decimal, error)
+	HourlyCountAt(ctx context.Context, userID uuid.UUID, at time.Time) (int, error)
+}
+
+// AccountStateCache keeps rebuilt account states.
+type AccountStateCache interface {
+	// Get returns nil when the state is not cached.
+	Get(ctx context.Context, userID uuid.UUID, at time.Time) (*AccountState, error)
+	Set(ctx context.Context, st *AccountState, ttl time.Duration) error
+}
+
+// WithBalanceHistory lets support rebuild a user's balances and limit usage
+// at a past moment. States of moments that have settled are cached for
+// cacheTTL; cache may be nil.
+func (s *Service) WithBalanceHistory(h BalanceHistory, cache AccountStateCache, cacheTTL time.Duration) *Service {
+	s.balanceHistory = h
+	s.stateCache = cache
+	s.stateCacheTTL = cacheTTL
+	return s
+}
+
+// AccountState is a user's wallet balances and limit usage at At. Limits
+// are the user's current ones; only their usage is as it stood at At.
+type AccountState struct {
+	UserID     uuid.UUID                `json:"user_id"`
+	At         time.Time                `json:"at"`
+	Wallets    []domain.WalletBalanceAt `json:"wallets"`
+	Limits     *TransactionLimits       `json:"limits"`
+	ComputedAt time.Time                `json:"computed_at"`
+	Cached     bool                     `json:"cached"`
+}
+
+// AccountStateViewer is the staff member rebuilding a state, recorded in
+// the audit log.
+type AccountStateViewer struct {
+	AdminID uuid.UUID
+	IP      string
+	Reason  string
+}
+
+// AccountStateAt rebuilds the user's balances and limit usage at at, for
+// support investigations. Every view is audited, cached or not.
+func (s *Service) AccountStateAt(ctx context.Context, userID uuid.UUID, at time.Time, viewer AccountStateViewer) (*AccountState, error) {
+	if s.balanceHistory == nil {
+		return nil, ErrAccountStateDisabled
+	}
+	now := time.Now().UTC()
+	if at.IsZero() {
+		return nil, fmt.Errorf("%w: at is required", ErrInvalidStateTime)
+	}
+	at = at.UTC()
+	if at.After(now) {
+		return nil, fmt.Errorf("%w: at must not be in the future", ErrInvalidStateTime)
+	}
+	settled := !at.After(now.Add(-accountStateSettle))
+
+	if settled && s.stateCache != nil {
+		st, err := s.stateCache.Get(ctx, userID, at)
+		if err != nil {
+			s.logger.Warn("Failed to read cached account state", map[string]interface{}{"user_id": userID, "error": err.Error()})
+		} else if st != nil {
+			st.Cached = true
+			s.auditAccountState(ctx, st, viewer)
+			return st, nil
+		}
+	}
+
+	limits, err := s.transactionLimits(ctx, userID, limitUsage{
+		daily: func(c domain.Currency) (decimal.Decimal, error) {
+			return s.balanceHistory.DailyTotalAt(ctx, userID, c, at)
+		},
+		hourly: func() (int, error) { return s.balanceHistory.HourlyCountAt(ctx, userID, at) },
+		wallet: func(w *domain.Wallet) bool { return !w.CreatedAt.After(at) },
+	})
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := s.balanceHistory.WalletBalancesAt(ctx, userID, at)
+	if err != nil {
+		return nil, err
+	}
+	for i := range wallets {
+		w := &wallets[i]
+		w.Consistent = w.EntryBalance == nil || w.EntryBalance.Equal(w.Balance)
+	}
+	st := &AccountState{UserID: userID, At: at, Wallets: wallets, Limits: limits, ComputedAt: now}
+
+	if settled && s.stateCache != nil {
+		if err := s.stateCache.Set(ctx, st, s.stateCacheTTL); err != nil {
+			s.logger.Warn("Failed to cache account state", map[string]interface{}{"user_id": userID, "error": err.Error()})
+		}
+	}
+	s.auditAccountState(ctx, st, viewer)
+	return st, nil
+}
+
+func (s *Service) auditAccountState(ctx context.Context, st *AccountState, viewer AccountStateViewer) {
+	adminID := viewer.AdminID
+	entry := &domain.AuditLog{
+		ID:         uuid.New(),
+		Action:     "ACCOUNT_STATE_VIEWED",
+		EntityType: "user",
+		EntityID:   st.UserID.String(),
+		UserID:     &adminID,
+		IPAddress:  viewer.IP,
+		Status:     "success",
+		Metadata: domain.Metadata{
+			"at":     st.At.Format(time.RFC3339Nano),
+			"reason": viewer.Reason,
+			"cached": st.Cached,
+		},
+		CreatedAt: time.Now(),
+	}
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		s.logger.Error("Failed to audit account state view", map[string]interface{}{"error": err.Error(), "user_id": st.UserID})
+	}
+}
+
+// SnapshotBalances copies every wallet's balances for the period of
+// interval now falls in, and drops copies older than retention when it is
+// set. Balances at a moment are rebuilt from the copy before it, so the
+// interval bounds the ledger entries replayed.
+func (s *Service) SnapshotBalances(ctx context.Context, now time.Time, interval, retention time.Duration) (int, error) {
+	if s.balanceHistory == nil {
+		return 0, ErrAccountStateDisabled
+	}
+	n, err := s.balanceHistory.TakeBalanceSnapshots(ctx, now.UTC().Truncate(interval))
+	if err != nil {
+		return 0, err
+	}
+	if retention > 0 {
+		if _, err := s.balanceHistory.PruneBalanceSnapshots(ctx, now.Add(-retention)); err != nil {
+			return n, pkgerrors.Wrap(err, "failed to prune balance snapshots")
+		}
+	}
+	return n, nil
+}
+
+// BalanceSnapshotWorker takes the balance snapshots when it starts and
+// then every interval.
+type BalanceSnapshotWorker struct {
+	service   *Service
+	interval  time.Duration
+	retention time.Duration
+	logger    logger.Logger
+	pulse     heartbeat.Pulse
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewBalanceSnapshotWorker keeps snapshots for retention, or indefinitely
+// when it is zero.
+func NewBalanceSnapshotWorker(service *Service, interval, retention time.Duration, log logger.Logger) *BalanceSnapshotWorker {
+	return &BalanceSnapshotWorker{service: service, interval: interval, retention: retention, logger: log, pulse: heartbeat.Nop, stop: make(chan struct{})}
+}
+
+// WithHeartbeat beats p after every pass.
+func (w *BalanceSnapshotWorker) WithHeartbeat(p heartbeat.Pulse) *BalanceSnapshotWorker {
+	w.pulse = p
+	return w
+}
+
+func (w *BalanceSnapshotWorker) Start() {
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			n, err := w.service.SnapshotBalances(context.Background(), time.Now(), w.interval, w.retention)
+			if err != nil {
+				w.logger.Error("Failed to snapshot wallet balances", map[string]interface{}{"error": err.Error()})
+			} else if n > 0 {
+				w.logger.Info("Snapshotted wallet balances", map[string]interface{}{"wallets": n})
+			}
+			w.pulse.Beat()
+			select {
+			case <-ticker.C:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	w.logger.Info("Balance snapshots started", map[string]interface{}{"interval": w.interval.String()})
+}
+
+func (w *BalanceSnapshotWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
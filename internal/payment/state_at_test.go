@@ -0,0 +1,162 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memBalanceHistory answers every moment with the same usage, counting the
+// rebuilds it is asked for.
+type memBalanceHistory struct {
+	balances []domain.WalletBalanceAt
+	daily    map[domain.Currency]decimal.Decimal
+	hourly   int
+	rebuilds int
+	periods  []time.Time
+	prunedTo time.Time
+	askedAt  time.Time
+}
+
+func (m *memBalanceHistory) TakeBalanceSnapshots(_ context.Context, periodStart time.Time) (int, error) {
+	m.periods = append(m.periods, periodStart)
+	return len(m.balances), nil
+}
+
+func (m *memBalanceHistory) PruneBalanceSnapshots(_ context.Context, before time.Time) (int, error) {
+	m.prunedTo = before
+	return 0, nil
+}
+
+func (m *memBalanceHistory) WalletBalancesAt(_ context.Context, _ uuid.UUID, at time.Time) ([]domain.WalletBalanceAt, error) {
+	m.rebuilds++
+	m.askedAt = at
+	return append([]domain.WalletBalanceAt(nil), m.balances...), nil
+}
+
+func (m *memBalanceHistory) DailyTotalAt(_ context.Context, _ uuid.UUID, c domain.Currency, _ time.Time) (decimal.Decimal, error) {
+	return m.daily[c], nil
+}
+
+func (m *memBalanceHistory) HourlyCountAt(context.Context, uuid.UUID, time.Time) (int, error) {
+	return m.hourly, nil
+}
+
+type memStateCache map[string]AccountState
+
+func (c memStateCache) Get(_ context.Context, userID uuid.UUID, at time.Time) (*AccountState, error) {
+	st, ok := c[accountStateKey(userID, at)]
+	if !ok {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (c memStateCache) Set(_ context.Context, st *AccountState, _ time.Duration) error {
+	c[accountStateKey(st.UserID, st.At)] = *st
+	return nil
+}
+
+func TestAccountStateAt(t *testing.T) {
+	ctx := context.Background()
+	user := &domain.User{ID: uuid.New(), KYCLevel: 2, KYCStatus: domain.KYCStatusVerified}
+	now := time.Now().UTC()
+	mwk := &domain.Wallet{ID: uuid.New(), UserID: user.ID, Currency: domain.MWK, CreatedAt: now.Add(-30 * 24 * time.Hour)}
+	zar := &domain.Wallet{ID: uuid.New(), UserID: user.ID, Currency: domain.ZAR, CreatedAt: now.Add(-time.Hour)}
+
+	users := new(MockUserRepository)
+	users.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+	users.On("FindByID", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	wallets := new(MockWalletRepository)
+	wallets.On("FindByUserID", mock.Anything, mock.Anything).Return([]*domain.Wallet{mwk, zar}, nil)
+	audit := new(MockAuditRepository)
+	var audited []*domain.AuditLog
+	audit.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		audited = append(audited, args.Get(1).(*domain.AuditLog))
+	}).Return(nil)
+
+	recorded := decimal.NewFromInt(700)
+	history := &memBalanceHistory{
+		balances: []domain.WalletBalanceAt{
+			{WalletID: mwk.ID, Currency: domain.MWK, Balance: decimal.NewFromInt(750), EntriesReplayed: 2, EntryBalance: &recorded},
+		},
+		daily:  map[domain.Currency]decimal.Decimal{domain.MWK: decimal.NewFromInt(95000)},
+		hourly: 3,
+	}
+	cache := memStateCache{}
+	s := NewService(new(MockRepository), wallets, new(MockForexService), new(MockLedgerService), users, new(MockNotificationService), audit, new(MockSecurityRepository), logger.NewNop(), nil)
+
+	viewer := AccountStateViewer{AdminID: uuid.New(), IP: "10.0.0.9", Reason: "ticket 4411"}
+	_, err := s.AccountStateAt(ctx, user.ID, now.Add(-24*time.Hour), viewer)
+	assert.ErrorIs(t, err, ErrAccountStateDisabled)
+
+	s.WithBalanceHistory(history, cache, time.Hour)
+	_, err = s.AccountStateAt(ctx, user.ID, now.Add(time.Hour), viewer)
+	assert.ErrorIs(t, err, ErrInvalidStateTime, "the future")
+	_, err = s.AccountStateAt(ctx, user.ID, time.Time{}, viewer)
+	assert.ErrorIs(t, err, ErrInvalidStateTime)
+
+	at := now.Add(-24 * time.Hour)
+	st, err := s.AccountStateAt(ctx, user.ID, at, viewer)
+	require.NoError(t, err)
+	assert.False(t, st.Cached)
+	assert.Equal(t, at, history.askedAt)
+	require.Len(t, st.Wallets, 1)
+	assert.False(t, st.Wallets[0].Consistent, "the replay disagrees with the entry")
+	require.Len(t, st.Limits.Daily, 1, "the ZAR wallet did not exist yet")
+	assert.Equal(t, "100000", st.Limits.Daily[0].Limit.String(), "the risk engine caps the KYC tier")
+	assert.Equal(t, "95000", st.Limits.Daily[0].Used.String())
+	assert.Equal(t, "5000", st.Limits.Daily[0].Remaining.String())
+	assert.Equal(t, 3, st.Limits.Hourly.Used)
+
+	// A settled moment is served from the cache, and still audited.
+	st, err = s.AccountStateAt(ctx, user.ID, at, viewer)
+	require.NoError(t, err)
+	assert.True(t, st.Cached)
+	assert.Equal(t, 1, history.rebuilds)
+
+	// A recent one may still change, so it is rebuilt every time.
+	recent := now.Add(-time.Minute)
+	for i := 0; i < 2; i++ {
+		st, err = s.AccountStateAt(ctx, user.ID, recent, viewer)
+		require.NoError(t, err)
+		assert.False(t, st.Cached)
+	}
+	assert.Equal(t, 3, history.rebuilds)
+	assert.Len(t, st.Limits.Daily, 2)
+
+	require.Len(t, audited, 4)
+	assert.Equal(t, "ACCOUNT_STATE_VIEWED", audited[0].Action)
+	assert.Equal(t, user.ID.String(), audited[0].EntityID)
+	assert.Equal(t, viewer.AdminID, *audited[0].UserID)
+	assert.Equal(t, "10.0.0.9", audited[0].IPAddress)
+	assert.Equal(t, "ticket 4411", audited[0].Metadata["reason"])
+	assert.Equal(t, false, audited[0].Metadata["cached"])
+	assert.Equal(t, true, audited[1].Metadata["cached"])
+
+	_, err = s.AccountStateAt(ctx, uuid.New(), at, viewer)
+	assert.Error(t, err, "unknown user")
+	assert.Len(t, audited, 4, "failed views are not audited as seen")
+}
+
+func TestSnapshotBalances(t *testing.T) {
+	history := &memBalanceHistory{balances: []domain.WalletBalanceAt{{}, {}}}
+	s := NewService(new(MockRepository), new(MockWalletRepository), new(MockForexService), new(MockLedgerService), new(MockUserRepository), new(MockNotificationService), nil, new(MockSecurityRepository), logger.NewNop(), nil).
+		WithBalanceHistory(history, nil, 0)
+	now := time.Date(2026, 10, 14, 14, 32, 0, 0, time.UTC)
+
+	n, err := s.SnapshotBalances(context.Background(), now, 24*time.Hour, 90*24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []time.Time{time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)}, history.periods)
+	assert.Equal(t, now.Add(-90*24*time.Hour), history.prunedTo)
+}
@@ -0,0 +1,50 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const accountStateKeyPrefix = "account_state:"
+
+// RedisAccountStateCache keeps rebuilt account states in Redis, shared by
+// all services.
+type RedisAccountStateCache struct {
+	client *redis.Client
+}
+
+func NewRedisAccountStateCache(client *redis.Client) *RedisAccountStateCache {
+	return &RedisAccountStateCache{client: client}
+}
+
+func accountStateKey(userID uuid.UUID, at time.Time) string {
+	return accountStateKeyPrefix + userID.String() + ":" + at.UTC().Format(time.RFC3339Nano)
+}
+
+func (c *RedisAccountStateCache) Get(ctx context.Context, userID uuid.UUID, at time.Time) (*AccountState, error) {
+	data, err := c.client.Get(ctx, accountStateKey(userID, at)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st AccountState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (c *RedisAccountStateCache) Set(ctx context.Context, st *AccountState, ttl time.Duration) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, accountStateKey(st.UserID, st.At), data, ttl).Err()
+}
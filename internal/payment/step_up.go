@@ -0,0 +1,126 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/monitoring"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrStepUpRequired is returned when a payment or change needs the
+	// sender to re-authenticate and no step-up proof was given.
+	ErrStepUpRequired = errors.New("security alert: step-up verification required")
+	// ErrStepUpFailed is returned when the step-up proof was wrong.
+	ErrStepUpFailed = errors.New("step-up verification failed")
+)
+
+// StepUp is the proof a customer gives to re-authenticate for a sensitive
+// action: their TOTP code when they have enabled TOTP, otherwise their
+// password.
+type StepUp struct {
+	Password string `json:"password,omitempty"`
+	TOTPCode string `json:"totp_code,omitempty"`
+}
+
+// StepUpVerifier checks a step-up proof for a signed-in user.
+type StepUpVerifier interface {
+	VerifyStepUp(ctx context.Context, userID uuid.UUID, password, totpCode string) error
+}
+
+// StepUpPolicy decides which payments need step-up verification.
+type StepUpPolicy struct {
+	// Threshold is the amount from which every payment needs step-up; zero
+	// turns payment step-up off.
+	Threshold decimal.Decimal
+	// TrustedMaxAmount is the largest payment to an active trusted
+	// beneficiary that skips step-up.
+	TrustedMaxAmount decimal.Decimal
+	// CoolingOff is how long a new trusted beneficiary waits before it
+	// counts.
+	CoolingOff time.Duration
+}
+
+// WithStepUp lets payments over the step-up threshold, and changes to
+// trusted beneficiaries, go ahead once the customer re-authenticates.
+func (s *Service) WithStepUp(verifier StepUpVerifier, policy StepUpPolicy) *Service {
+	s.stepUp = verifier
+	s.stepUpPolicy = policy
+	return s
+}
+
+// verifyStepUp checks the customer's step-up proof.
+func (s *Service) verifyStepUp(ctx context.Context, userID uuid.UUID, proof *StepUp) error {
+	if s.stepUp == nil || proof == nil {
+		return ErrStepUpRequired
+	}
+	if err := s.stepUp.VerifyStepUp(ctx, userID, proof.Password, proof.TOTPCode); err != nil {
+		return fmt.Errorf("%w: %w", ErrStepUpFailed, err)
+	}
+	return nil
+}
+
+// blocksPayment reports whether an anomaly stops the payment outright.
+func blocksPayment(a monitoring.Anomaly) bool {
+	return a.Severity == "HIGH" || a.Severity == "CRITICAL"
+}
+
+// challengePayment decides whether the payment needs step-up verification
+// because of its amount, and if so whether it skips it as a small payment
+// to a trusted beneficiary or passes with the proof on req. A move between
+// the sender's own wallets never leaves them and needs none. Anomalies are
+// handled before this and are never skipped.
+func (s *Service) challengePayment(ctx context.Context, req *InitiatePaymentRequest, receiverWallet *domain.Wallet) error {
+	threshold := s.stepUpPolicy.Threshold
+	if !threshold.IsPositive() || req.Amount.LessThan(threshold) {
+		return nil
+	}
+	if receiverWallet != nil && receiverWallet.UserID == req.SenderID {
+		return nil
+	}
+	reason := fmt.Sprintf("payments of %s or more need verification", threshold.String())
+
+	if s.isTrustedPayment(ctx, req, receiverWallet) {
+		s.logger.Info("Step-up skipped for trusted beneficiary", map[string]interface{}{
+			"sender_id": req.SenderID,
+			"wallet_id": receiverWallet.ID,
+			"reason":    reason,
+		})
+		return nil
+	}
+
+	err := s.verifyStepUp(ctx, req.SenderID, req.StepUp)
+	if err == nil {
+		return nil
+	}
+	s.logger.Warn("Payment held for step-up verification", map[string]interface{}{
+		"sender_id": req.SenderID,
+		"reason":    reason,
+		"error":     err.Error(),
+	})
+	if errors.Is(err, ErrStepUpRequired) {
+		return fmt.Errorf("%w: %s", ErrStepUpRequired, reason)
+	}
+	return err
+}
+
+// isTrustedPayment reports whether the payment goes to one of the sender's
+// active trusted beneficiaries and is within the trusted amount. A failed
+// lookup is treated as untrusted, which only adds friction.
+func (s *Service) isTrustedPayment(ctx context.Context, req *InitiatePaymentRequest, receiverWallet *domain.Wallet) bool {
+	if s.beneficiaries == nil || receiverWallet == nil || req.Amount.GreaterThan(s.stepUpPolicy.TrustedMaxAmount) {
+		return false
+	}
+	b, err := s.beneficiaries.Find(ctx, req.SenderID, receiverWallet.ID)
+	if err != nil {
+		s.logger.Warn("Failed to check trusted beneficiary", map[string]interface{}{"sender_id": req.SenderID, "error": err.Error()})
+		return false
+	}
+	return b != nil && b.IsActive(time.Now())
+}
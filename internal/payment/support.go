@@ -0,0 +1,152 @@
+package payment
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"kyd/internal/domain"
+	"kyd/pkg/reference"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrSupportLookupMismatch is returned whenever a support lookup does not
+// identify a transaction. Unknown references and wrong phone digits or
+// amounts are deliberately indistinguishable.
+var ErrSupportLookupMismatch = errors.New("no transaction matches these details")
+
+// Support lookup outcomes, recorded in the audit log.
+const (
+	SupportLookupMatched  = "matched"
+	SupportLookupMismatch = "mismatch"
+	SupportLookupLocked   = "locked"
+)
+
+// SupportLookupRequest is what a caller reads out to a call-centre agent.
+type SupportLookupRequest struct {
+	Reference  string          `json:"reference" validate:"required,max=64"`
+	PhoneLast4 string          `json:"phone_last4" validate:"required,len=4,numeric"`
+	Amount     decimal.Decimal `json:"amount"`
+	// AgentID identifies the agent for the audit trail.
+	AgentID string `json:"agent_id" validate:"omitempty,max=64"`
+}
+
+// SupportLookupResult is the masked view of a transaction an agent may see
+// without the customer logging in: enough to triage, nothing to act on.
+type SupportLookupResult struct {
+	ReferenceNumber string                   `json:"reference_number,omitempty"`
+	Status          domain.TransactionStatus `json:"status"`
+	NextStep        string                   `json:"next_step,omitempty"`
+	Amount          decimal.Decimal          `json:"amount"`
+	Currency        domain.Currency          `json:"currency"`
+	Sender          string                   `json:"sender"`
+	Receiver        string                   `json:"receiver"`
+	CreatedAt       time.Time                `json:"created_at"`
+	CompletedAt     *time.Time               `json:"completed_at,omitempty"`
+}
+
+// SupportLookupKey is the canonical form of a lookup's reference, used to
+// count failed attempts against it.
+func SupportLookupKey(ref string) string {
+	if number, err := reference.Parse(ref); err == nil {
+		return number
+	}
+	return strings.TrimSpace(ref)
+}
+
+// SupportLookup finds the transaction with the given reference whose sender's
+// phone number ends in PhoneLast4 and whose amount matches, and returns its
+// masked status. Every attempt is audited; ip is the caller's address.
+func (s *Service) SupportLookup(ctx context.Context, req SupportLookupRequest, ip string) (*SupportLookupResult, error) {
+	tx, err := s.matchSupportLookup(ctx, req)
+	if err != nil {
+		s.AuditSupportLookup(ctx, req, ip, SupportLookupMismatch, nil)
+		return nil, err
+	}
+	s.AuditSupportLookup(ctx, req, ip, SupportLookupMatched, &tx.ID)
+
+	result := &SupportLookupResult{
+		ReferenceNumber: tx.ReferenceNumber,
+		Status:          tx.Status,
+		NextStep:        buildTimeline(tx, nil).NextStep,
+		Amount:          tx.Amount,
+		Currency:        tx.Currency,
+		CreatedAt:       tx.CreatedAt,
+		CompletedAt:     tx.CompletedAt,
+	}
+	if sender, err := s.userRepo.FindByID(ctx, tx.SenderID); err == nil {
+		result.Sender = maskName(sender.FirstName, sender.LastName)
+	}
+	if receiver, err := s.userRepo.FindByID(ctx, tx.ReceiverID); err == nil {
+		result.Receiver = maskName(receiver.FirstName, receiver.LastName)
+	}
+	return result, nil
+}
+
+func (s *Service) matchSupportLookup(ctx context.Context, req SupportLookupRequest) (*domain.Transaction, error) {
+	detail, err := s.GetTransactionByReference(ctx, req.Reference)
+	if err != nil {
+		return nil, ErrSupportLookupMismatch
+	}
+	tx := detail.Transaction
+	if !tx.Amount.Equal(req.Amount) {
+		return nil, ErrSupportLookupMismatch
+	}
+	sender, err := s.userRepo.FindByID(ctx, tx.SenderID)
+	if err != nil {
+		return nil, ErrSupportLookupMismatch
+	}
+	digits := phoneDigits(sender.Phone)
+	if len(digits) < 4 || subtle.ConstantTimeCompare([]byte(digits[len(digits)-4:]), []byte(req.PhoneLast4)) != 1 {
+		return nil, ErrSupportLookupMismatch
+	}
+	return tx, nil
+}
+
+// AuditSupportLookup records a support lookup attempt. The phone digits are
+// never stored.
+func (s *Service) AuditSupportLookup(ctx context.Context, req SupportLookupRequest, ip, outcome string, txID *uuid.UUID) {
+	entry := &domain.AuditLog{
+		ID:         uuid.New(),
+		Action:     "SUPPORT_LOOKUP",
+		EntityType: "transaction",
+		IPAddress:  ip,
+		Status:     outcome,
+		Metadata: domain.Metadata{
+			"reference": SupportLookupKey(req.Reference),
+			"agent_id":  req.AgentID,
+		},
+		CreatedAt: time.Now(),
+	}
+	if txID != nil {
+		entry.EntityID = txID.String()
+	}
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		s.logger.Error("Failed to audit support lookup", map[string]interface{}{"error": err.Error(), "outcome": outcome})
+	}
+}
+
+func phoneDigits(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// maskName keeps initials only: "Jane Doe" becomes "J*** D***".
+func maskName(first, last string) string {
+	var parts []string
+	for _, n := range []string{first, last} {
+		if r := []rune(strings.TrimSpace(n)); len(r) > 0 {
+			parts = append(parts, string(r[0])+"***")
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/reference"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupportLookup(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	mockAudit := new(MockAuditRepository)
+	service := NewService(mockRepo, new(MockWalletRepository), new(MockForexService), new(MockLedgerService), mockUserRepo, new(MockNotificationService), mockAudit, new(MockSecurityRepository), new(MockLogger), nil)
+
+	ctx := context.Background()
+	number, err := reference.New()
+	require.NoError(t, err)
+	sender := &domain.User{ID: uuid.New(), FirstName: "Jane", LastName: "Banda", Phone: "+265 991 234 567"}
+	receiver := &domain.User{ID: uuid.New(), FirstName: "Li", LastName: "Wei"}
+	tx := &domain.Transaction{
+		ID: uuid.New(), ReferenceNumber: number, SenderID: sender.ID, ReceiverID: receiver.ID,
+		Amount: decimal.NewFromInt(25000), Currency: domain.MWK, Status: domain.TransactionStatusCompleted, CreatedAt: time.Now(),
+	}
+
+	mockRepo.On("FindByReferenceNumber", ctx, number).Return(tx, nil)
+	mockUserRepo.On("FindByID", ctx, sender.ID).Return(sender, nil)
+	mockUserRepo.On("FindByID", ctx, receiver.ID).Return(receiver, nil)
+	var audited []*domain.AuditLog
+	mockAudit.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		audited = append(audited, args.Get(1).(*domain.AuditLog))
+	}).Return(nil)
+
+	req := SupportLookupRequest{Reference: number, PhoneLast4: "4567", Amount: decimal.NewFromInt(25000), AgentID: "agent-7"}
+	res, err := service.SupportLookup(ctx, req, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.TransactionStatusCompleted, res.Status)
+	assert.Equal(t, "J*** B***", res.Sender)
+	assert.Equal(t, "L*** W***", res.Receiver)
+
+	// Wrong digits and wrong amount look the same as an unknown reference.
+	for _, bad := range []SupportLookupRequest{
+		{Reference: number, PhoneLast4: "4568", Amount: decimal.NewFromInt(25000)},
+		{Reference: number, PhoneLast4: "4567", Amount: decimal.NewFromInt(2500)},
+	} {
+		_, err := service.SupportLookup(ctx, bad, "10.0.0.1")
+		assert.ErrorIs(t, err, ErrSupportLookupMismatch)
+	}
+
+	require.Len(t, audited, 3)
+	assert.Equal(t, SupportLookupMatched, audited[0].Status)
+	assert.Equal(t, tx.ID.String(), audited[0].EntityID)
+	assert.Equal(t, "agent-7", audited[0].Metadata["agent_id"])
+	assert.Equal(t, SupportLookupMismatch, audited[1].Status)
+	assert.NotContains(t, audited[1].Metadata, "phone_last4")
+}
@@ -0,0 +1,283 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// EventRepository persists the transaction event stream.
+type EventRepository interface {
+	Append(ctx context.Context, e *domain.TransactionEvent) error
+	ListByTransaction(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionEvent, error)
+}
+
+// WithEventStream records lifecycle milestones for each transaction so that
+// customers can follow their payment on a timeline.
+func (s *Service) WithEventStream(repo EventRepository) *Service {
+	s.events = repo
+	return s
+}
+
+// recordEvent appends a milestone to the event stream. Failures are logged and
+// never block the payment itself.
+func (s *Service) recordEvent(ctx context.Context, txID uuid.UUID, t domain.TransactionEventType, details domain.Metadata) {
+	if s.events == nil {
+		return
+	}
+	err := s.events.Append(ctx, &domain.TransactionEvent{
+		ID:            uuid.New(),
+		TransactionID: txID,
+		Type:          t,
+		Details:       details,
+		CreatedAt:     time.Now(),
+	})
+	if err != nil {
+		s.logger.Warn("Failed to record transaction event", map[string]interface{}{
+			"transaction_id": txID,
+			"event":          t,
+			"error":          err.Error(),
+		})
+	}
+}
+
+const (
+	MilestoneCompleted = "completed"
+	MilestoneCurrent   = "current"
+	MilestoneUpcoming  = "upcoming"
+	MilestoneFailed    = "failed"
+)
+
+// TimelineMilestone is one step of a payment as shown to the customer.
+type TimelineMilestone struct {
+	Key         domain.TransactionEventType `json:"key"`
+	Title       string                      `json:"title"`
+	Description string                      `json:"description,omitempty"`
+	Status      string                      `json:"status"`
+	At          *time.Time                  `json:"at,omitempty"`
+}
+
+type Timeline struct {
+	TransactionID uuid.UUID                `json:"transaction_id"`
+	Reference     string                   `json:"reference"`
+	Status        domain.TransactionStatus `json:"status"`
+	Milestones    []*TimelineMilestone     `json:"milestones"`
+	NextStep      string                   `json:"next_step,omitempty"`
+}
+
+type milestoneSpec struct {
+	key   domain.TransactionEventType
+	title string
+	// next is shown to the customer while this milestone is the one being worked on.
+	next string
+}
+
+var terminalEvents = map[domain.TransactionEventType]string{
+	domain.TransactionEventFailed:    "Payment failed",
+	domain.TransactionEventCancelled: "Payment cancelled",
+	domain.TransactionEventReversed:  "Payment reversed",
+}
+
+func timelineSpecs(tx *domain.Transaction) []milestoneSpec {
+	specs := []milestoneSpec{
+		{domain.TransactionEventInitiated, "Payment initiated", "We are setting up your payment."},
+		{domain.TransactionEventCompliancePassed, "Compliance checks passed", "We are running routine compliance checks."},
+	}
+	if tx.ConvertedCurrency != "" && tx.ConvertedCurrency != tx.Currency {
+		specs = append(specs, milestoneSpec{
+			domain.TransactionEventConverted, "Currency converted",
+			fmt.Sprintf("We are converting your %s to %s.", tx.Currency, tx.ConvertedCurrency),
+		})
+	}
+	return append(specs,
+		milestoneSpec{domain.TransactionEventSubmitted, "Submitted to settlement network", "Your payment will join the next settlement batch, usually within a few minutes."},
+		milestoneSpec{domain.TransactionEventConfirmed, "Confirmed on network", "We are waiting for the settlement network to confirm the transfer."},
+		milestoneSpec{domain.TransactionEventDelivered, "Delivered", "The funds are on their way to the recipient."},
+	)
+}
+
+// inferEvents reconstructs milestones for transactions created before the
+// event stream existed, using only what the transaction row records.
+func inferEvents(tx *domain.Transaction) []*domain.TransactionEvent {
+	at := func(t domain.TransactionEventType, when *time.Time) *domain.TransactionEvent {
+		e := &domain.TransactionEvent{TransactionID: tx.ID, Type: t}
+		if when != nil {
+			e.CreatedAt = *when
+		}
+		return e
+	}
+	initiated := tx.InitiatedAt
+	out := []*domain.TransactionEvent{at(domain.TransactionEventInitiated, &initiated)}
+
+	switch tx.Status {
+	case domain.TransactionStatusPending, domain.TransactionStatusPendingApproval, domain.TransactionStatusQueued:
+		return out
+	case domain.TransactionStatusFailed:
+		return append(out, at(domain.TransactionEventFailed, &tx.UpdatedAt))
+	case domain.TransactionStatusCancelled:
+		return append(out, at(domain.TransactionEventCancelled, tx.CompletedAt))
+	}
+
+	// The ledger posting that passes compliance and converts funds also sets CompletedAt.
+	out = append(out,
+		at(domain.TransactionEventCompliancePassed, tx.CompletedAt),
+		at(domain.TransactionEventConverted, tx.CompletedAt),
+	)
+	if tx.SettlementID != nil {
+		out = append(out, at(domain.TransactionEventSubmitted, nil))
+	}
+	switch tx.Status {
+	case domain.TransactionStatusCompleted:
+		out = append(out,
+			at(domain.TransactionEventConfirmed, tx.CompletedAt),
+			at(domain.TransactionEventDelivered, tx.CompletedAt),
+		)
+	case domain.TransactionStatusReversed, domain.TransactionStatusRefunded:
+		out = append(out, at(domain.TransactionEventReversed, &tx.UpdatedAt))
+	}
+	return out
+}
+
+// buildTimeline folds the event stream into the ordered milestone list. The
+// first occurrence of each milestone wins; a terminal event ends the timeline.
+func buildTimeline(tx *domain.Transaction, events []*domain.TransactionEvent) *Timeline {
+	if len(events) == 0 {
+		events = inferEvents(tx)
+	}
+
+	seen := make(map[domain.TransactionEventType]*domain.TransactionEvent)
+	var terminal, held *domain.TransactionEvent
+	for _, e := range events {
+		if _, ok := terminalEvents[e.Type]; ok {
+			terminal = e
+			continue
+		}
+		if e.Type == domain.TransactionEventHeldForReview {
+			held = e
+			continue
+		}
+		if _, ok := seen[e.Type]; !ok {
+			seen[e.Type] = e
+		}
+	}
+
+	tl := &Timeline{
+		TransactionID: tx.ID,
+		Reference:     tx.Reference,
+		Status:        tx.Status,
+		Milestones:    []*TimelineMilestone{},
+	}
+
+	// A milestone counts as reached once it or any later milestone has happened,
+	// so a missed event never leaves a gap in the middle of the timeline.
+	specs := timelineSpecs(tx)
+	reached := -1
+	for i, spec := range specs {
+		if _, ok := seen[spec.key]; ok {
+			reached = i
+		}
+	}
+
+	for i, spec := range specs {
+		m := &TimelineMilestone{Key: spec.key, Title: spec.title, Status: MilestoneUpcoming}
+		if e, ok := seen[spec.key]; ok && !e.CreatedAt.IsZero() {
+			when := e.CreatedAt
+			m.At = &when
+		}
+		switch {
+		case i <= reached:
+			m.Status = MilestoneCompleted
+		case i == reached+1 && terminal == nil:
+			m.Status = MilestoneCurrent
+			m.Description = spec.next
+			tl.NextStep = spec.next
+			if spec.key == domain.TransactionEventCompliancePassed && held != nil {
+				m.Description = "Your payment is being reviewed by our compliance team. This usually takes less than one business day."
+				tl.NextStep = m.Description
+			}
+		}
+		if e := seen[spec.key]; e != nil && spec.key == domain.TransactionEventSubmitted {
+			if network, _ := e.Details["network"].(string); network != "" {
+				m.Description = fmt.Sprintf("Settling on %s.", network)
+			}
+		}
+		tl.Milestones = append(tl.Milestones, m)
+	}
+
+	if tx.Status == domain.TransactionStatusQueued && terminal == nil {
+		next := "Your payment is queued for the destination network's next settlement window."
+		if eta, ok := queuedUntil(tx); ok {
+			next = fmt.Sprintf("Your payment is queued for the destination network's next settlement window and is expected to go out at %s UTC. You can cancel it until then.", eta.UTC().Format("2 Jan 2006 15:04"))
+		}
+		for _, m := range tl.Milestones {
+			if m.Status == MilestoneCurrent {
+				m.Description = next
+			}
+		}
+		tl.NextStep = next
+	}
+
+	if terminal != nil {
+		m := &TimelineMilestone{
+			Key:    terminal.Type,
+			Title:  terminalEvents[terminal.Type],
+			Status: MilestoneFailed,
+		}
+		if !terminal.CreatedAt.IsZero() {
+			when := terminal.CreatedAt
+			m.At = &when
+		}
+		if reason, _ := terminal.Details["reason"].(string); reason != "" {
+			m.Description = reason
+		} else if tx.StatusReason != "" {
+			m.Description = tx.StatusReason
+		}
+		// Drop milestones that will now never happen.
+		kept := tl.Milestones[:0]
+		for _, ms := range tl.Milestones {
+			if ms.Status == MilestoneCompleted {
+				kept = append(kept, ms)
+			}
+		}
+		tl.Milestones = append(kept, m)
+		tl.NextStep = "No further steps. Contact support if you have questions about this payment."
+	}
+	return tl
+}
+
+// GetTimeline returns the customer-facing progress of a payment. Only the
+// sender and receiver may view it.
+func (s *Service) GetTimeline(ctx context.Context, txID, userID uuid.UUID) (*Timeline, error) {
+	tx, err := s.repo.FindByID(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if tx.SenderID != userID && tx.ReceiverID != userID {
+		return nil, errors.New("unauthorized access to transaction timeline")
+	}
+
+	var events []*domain.TransactionEvent
+	if s.events != nil {
+		if events, err = s.events.ListByTransaction(ctx, txID); err != nil {
+			return nil, err
+		}
+	}
+	return buildTimeline(tx, events), nil
+}
+
+// recordConversion marks the currency conversion milestone for cross-currency payments.
+func (s *Service) recordConversion(ctx context.Context, tx *domain.Transaction) {
+	if tx.ConvertedCurrency == tx.Currency {
+		return
+	}
+	s.recordEvent(ctx, tx.ID, domain.TransactionEventConverted, domain.Metadata{
+		"rate":               tx.ExchangeRate.String(),
+		"converted_amount":   tx.ConvertedAmount.String(),
+		"converted_currency": tx.ConvertedCurrency,
+	})
+}
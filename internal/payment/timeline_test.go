@@ -0,0 +1,93 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func timelineEvent(t domain.TransactionEventType, at time.Time, details domain.Metadata) *domain.TransactionEvent {
+	return &domain.TransactionEvent{ID: uuid.New(), Type: t, CreatedAt: at, Details: details}
+}
+
+func milestoneStatuses(tl *Timeline) map[domain.TransactionEventType]string {
+	out := make(map[domain.TransactionEventType]string)
+	for _, m := range tl.Milestones {
+		out[m.Key] = m.Status
+	}
+	return out
+}
+
+func TestBuildTimeline_InProgress(t *testing.T) {
+	now := time.Now()
+	tx := &domain.Transaction{ID: uuid.New(), Currency: domain.MWK, ConvertedCurrency: domain.CNY, Status: domain.TransactionStatusSettling}
+	events := []*domain.TransactionEvent{
+		timelineEvent(domain.TransactionEventInitiated, now.Add(-3*time.Minute), nil),
+		timelineEvent(domain.TransactionEventCompliancePassed, now.Add(-3*time.Minute), nil),
+		timelineEvent(domain.TransactionEventConverted, now.Add(-2*time.Minute), nil),
+		timelineEvent(domain.TransactionEventSubmitted, now.Add(-time.Minute), domain.Metadata{"network": "stellar"}),
+	}
+
+	tl := buildTimeline(tx, events)
+	assert.Len(t, tl.Milestones, 6)
+	st := milestoneStatuses(tl)
+	assert.Equal(t, MilestoneCompleted, st[domain.TransactionEventSubmitted])
+	assert.Equal(t, MilestoneCurrent, st[domain.TransactionEventConfirmed])
+	assert.Equal(t, MilestoneUpcoming, st[domain.TransactionEventDelivered])
+	assert.NotEmpty(t, tl.NextStep)
+	assert.Equal(t, "Settling on stellar.", tl.Milestones[3].Description)
+	assert.NotNil(t, tl.Milestones[3].At)
+}
+
+func TestBuildTimeline_SameCurrencySkipsConversion(t *testing.T) {
+	tx := &domain.Transaction{ID: uuid.New(), Currency: domain.MWK, ConvertedCurrency: domain.MWK, Status: domain.TransactionStatusPendingApproval}
+	events := []*domain.TransactionEvent{
+		timelineEvent(domain.TransactionEventInitiated, time.Now(), nil),
+		timelineEvent(domain.TransactionEventHeldForReview, time.Now(), nil),
+	}
+
+	tl := buildTimeline(tx, events)
+	assert.Len(t, tl.Milestones, 5)
+	st := milestoneStatuses(tl)
+	assert.NotContains(t, st, domain.TransactionEventConverted)
+	assert.Equal(t, MilestoneCurrent, st[domain.TransactionEventCompliancePassed])
+	assert.Contains(t, tl.NextStep, "compliance team")
+}
+
+func TestBuildTimeline_Failed(t *testing.T) {
+	tx := &domain.Transaction{ID: uuid.New(), Currency: domain.MWK, ConvertedCurrency: domain.MWK, Status: domain.TransactionStatusFailed}
+	events := []*domain.TransactionEvent{
+		timelineEvent(domain.TransactionEventInitiated, time.Now(), nil),
+		timelineEvent(domain.TransactionEventFailed, time.Now(), domain.Metadata{"reason": "Your payment did not pass compliance review."}),
+	}
+
+	tl := buildTimeline(tx, events)
+	assert.Len(t, tl.Milestones, 2)
+	last := tl.Milestones[1]
+	assert.Equal(t, MilestoneFailed, last.Status)
+	assert.Equal(t, "Your payment did not pass compliance review.", last.Description)
+}
+
+func TestBuildTimeline_InfersLegacyTransactions(t *testing.T) {
+	completed := time.Now()
+	settlementID := uuid.New()
+	tx := &domain.Transaction{
+		ID:                uuid.New(),
+		Currency:          domain.MWK,
+		ConvertedCurrency: domain.CNY,
+		Status:            domain.TransactionStatusCompleted,
+		SettlementID:      &settlementID,
+		InitiatedAt:       completed.Add(-time.Hour),
+		CompletedAt:       &completed,
+	}
+
+	tl := buildTimeline(tx, nil)
+	for _, m := range tl.Milestones {
+		assert.Equal(t, MilestoneCompleted, m.Status)
+	}
+	assert.Empty(t, tl.NextStep)
+}
@@ -0,0 +1,136 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+const maxNicknameLength = 64
+
+var (
+	// ErrInvalidBeneficiary is returned for a beneficiary that cannot be
+	// trusted, such as the customer's own wallet or one already trusted.
+	ErrInvalidBeneficiary = errors.New("invalid beneficiary")
+	// ErrBeneficiaryNotFound is returned for a beneficiary the customer has
+	// not trusted.
+	ErrBeneficiaryNotFound = errors.New("trusted beneficiary not found")
+	// ErrBeneficiariesDisabled is returned when trusted beneficiaries or
+	// step-up verification are not configured.
+	ErrBeneficiariesDisabled = errors.New("trusted beneficiaries are not enabled")
+)
+
+// TrustedBeneficiaryRepository persists customers' trusted beneficiaries.
+type TrustedBeneficiaryRepository interface {
+	Create(ctx context.Context, b *domain.TrustedBeneficiary) error
+	// Delete removes the user's beneficiary, reporting whether they had one
+	// with that ID.
+	Delete(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*domain.TrustedBeneficiary, error)
+	// Find returns the user's beneficiary for a wallet, or nil.
+	Find(ctx context.Context, userID, walletID uuid.UUID) (*domain.TrustedBeneficiary, error)
+}
+
+// WithTrustedBeneficiaries lets customers whitelist wallets that small
+// payments reach without step-up verification. It needs WithStepUp, which
+// guards changes to the whitelist.
+func (s *Service) WithTrustedBeneficiaries(repo TrustedBeneficiaryRepository) *Service {
+	s.beneficiaries = repo
+	return s
+}
+
+// ListTrustedBeneficiaries returns the customer's trusted beneficiaries,
+// including those still cooling off.
+func (s *Service) ListTrustedBeneficiaries(ctx context.Context, userID uuid.UUID) ([]*domain.TrustedBeneficiary, error) {
+	if s.beneficiaries == nil {
+		return nil, ErrBeneficiariesDisabled
+	}
+	return s.beneficiaries.List(ctx, userID)
+}
+
+// AddTrustedBeneficiary trusts the wallet with the given number after the
+// customer re-authenticates. It only counts once the cooling-off period has
+// passed, and the customer is notified so that a beneficiary they did not
+// add can be removed before then.
+func (s *Service) AddTrustedBeneficiary(ctx context.Context, userID uuid.UUID, walletNumber, nickname string, proof *StepUp) (*domain.TrustedBeneficiary, error) {
+	if s.beneficiaries == nil || s.stepUp == nil {
+		return nil, ErrBeneficiariesDisabled
+	}
+	nickname = strings.TrimSpace(nickname)
+	if utf8.RuneCountInString(nickname) > maxNicknameLength {
+		return nil, fmt.Errorf("%w: nickname must be at most %d characters", ErrInvalidBeneficiary, maxNicknameLength)
+	}
+	if err := s.verifyStepUp(ctx, userID, proof); err != nil {
+		return nil, err
+	}
+	wallet, err := s.walletRepo.FindByAddress(ctx, strings.TrimSpace(walletNumber))
+	if err != nil {
+		return nil, err
+	}
+	if wallet.UserID == userID {
+		return nil, fmt.Errorf("%w: you cannot trust your own wallet", ErrInvalidBeneficiary)
+	}
+	existing, err := s.beneficiaries.Find(ctx, userID, wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w: wallet is already trusted", ErrInvalidBeneficiary)
+	}
+
+	now := time.Now().UTC()
+	b := &domain.TrustedBeneficiary{
+		ID:         uuid.New(),
+		UserID:     userID,
+		WalletID:   wallet.ID,
+		Nickname:   nickname,
+		ActiveFrom: now.Add(s.stepUpPolicy.CoolingOff),
+		CreatedAt:  now,
+	}
+	if err := s.beneficiaries.Create(ctx, b); err != nil {
+		return nil, err
+	}
+	s.notifyBeneficiaryChange(userID, "TRUSTED_BENEFICIARY_ADDED", b)
+	return b, nil
+}
+
+// RemoveTrustedBeneficiary stops trusting a beneficiary after the customer
+// re-authenticates. Removal takes effect at once: it only adds friction.
+func (s *Service) RemoveTrustedBeneficiary(ctx context.Context, userID, id uuid.UUID, proof *StepUp) error {
+	if s.beneficiaries == nil || s.stepUp == nil {
+		return ErrBeneficiariesDisabled
+	}
+	if err := s.verifyStepUp(ctx, userID, proof); err != nil {
+		return err
+	}
+	removed, err := s.beneficiaries.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrBeneficiaryNotFound
+	}
+	s.notifyBeneficiaryChange(userID, "TRUSTED_BENEFICIARY_REMOVED", &domain.TrustedBeneficiary{ID: id})
+	return nil
+}
+
+func (s *Service) notifyBeneficiaryChange(userID uuid.UUID, event string, b *domain.TrustedBeneficiary) {
+	s.logger.Info("Trusted beneficiaries changed", map[string]interface{}{"user_id": userID, "event": event, "beneficiary_id": b.ID})
+	if s.notifier == nil {
+		return
+	}
+	data := map[string]interface{}{"beneficiary_id": b.ID}
+	if !b.ActiveFrom.IsZero() {
+		data["active_from"] = b.ActiveFrom
+	}
+	go func() {
+		_ = s.notifier.Notify(context.Background(), userID, event, data)
+	}()
+}
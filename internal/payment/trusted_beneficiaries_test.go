@@ -0,0 +1,146 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/monitoring"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memBeneficiaries struct {
+	items []*domain.TrustedBeneficiary
+}
+
+func (m *memBeneficiaries) Create(ctx context.Context, b *domain.TrustedBeneficiary) error {
+	cp := *b
+	m.items = append(m.items, &cp)
+	return nil
+}
+
+func (m *memBeneficiaries) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	for i, b := range m.items {
+		if b.ID == id && b.UserID == userID {
+			m.items = append(m.items[:i], m.items[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memBeneficiaries) List(ctx context.Context, userID uuid.UUID) ([]*domain.TrustedBeneficiary, error) {
+	out := []*domain.TrustedBeneficiary{}
+	for _, b := range m.items {
+		if b.UserID == userID {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func (m *memBeneficiaries) Find(ctx context.Context, userID, walletID uuid.UUID) (*domain.TrustedBeneficiary, error) {
+	for _, b := range m.items {
+		if b.UserID == userID && b.WalletID == walletID {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+// passwordVerifier accepts "secret" as every user's password.
+type passwordVerifier struct{}
+
+func (passwordVerifier) VerifyStepUp(ctx context.Context, userID uuid.UUID, password, totpCode string) error {
+	if password != "secret" {
+		return pkgerrors.ErrInvalidCredentials
+	}
+	return nil
+}
+
+type addressBook struct {
+	WalletRepository
+	wallets map[string]*domain.Wallet
+}
+
+func (a addressBook) FindByAddress(ctx context.Context, address string) (*domain.Wallet, error) {
+	if w, ok := a.wallets[address]; ok {
+		return w, nil
+	}
+	return nil, pkgerrors.ErrWalletNotFound
+}
+
+func newBeneficiaryService(coolingOff time.Duration) (*Service, *memBeneficiaries, *domain.Wallet) {
+	wallet := &domain.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: domain.MWK}
+	beneficiaries := &memBeneficiaries{}
+	s := NewService(nil, addressBook{wallets: map[string]*domain.Wallet{"1234567890": wallet}}, nil, nil, nil, nil, nil, nil, logger.NewNop(), nil).
+		WithStepUp(passwordVerifier{}, StepUpPolicy{
+			Threshold:        decimal.NewFromInt(10000),
+			TrustedMaxAmount: decimal.NewFromInt(20000),
+			CoolingOff:       coolingOff,
+		}).
+		WithTrustedBeneficiaries(beneficiaries)
+	return s, beneficiaries, wallet
+}
+
+func TestAddTrustedBeneficiary(t *testing.T) {
+	ctx := context.Background()
+	s, _, wallet := newBeneficiaryService(24 * time.Hour)
+	user := uuid.New()
+
+	_, err := s.AddTrustedBeneficiary(ctx, user, "1234567890", "Mum", nil)
+	assert.ErrorIs(t, err, ErrStepUpRequired)
+	_, err = s.AddTrustedBeneficiary(ctx, user, "1234567890", "Mum", &StepUp{Password: "wrong"})
+	assert.ErrorIs(t, err, ErrStepUpFailed)
+
+	b, err := s.AddTrustedBeneficiary(ctx, user, " 1234567890 ", "Mum", &StepUp{Password: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, wallet.ID, b.WalletID)
+	assert.False(t, b.IsActive(time.Now()), "a new beneficiary cools off first")
+	assert.True(t, b.IsActive(time.Now().Add(25*time.Hour)))
+
+	_, err = s.AddTrustedBeneficiary(ctx, user, "1234567890", "", &StepUp{Password: "secret"})
+	assert.ErrorIs(t, err, ErrInvalidBeneficiary, "already trusted")
+	_, err = s.AddTrustedBeneficiary(ctx, wallet.UserID, "1234567890", "", &StepUp{Password: "secret"})
+	assert.ErrorIs(t, err, ErrInvalidBeneficiary, "own wallet")
+
+	assert.ErrorIs(t, s.RemoveTrustedBeneficiary(ctx, user, b.ID, nil), ErrStepUpRequired)
+	require.NoError(t, s.RemoveTrustedBeneficiary(ctx, user, b.ID, &StepUp{Password: "secret"}))
+	assert.ErrorIs(t, s.RemoveTrustedBeneficiary(ctx, user, b.ID, &StepUp{Password: "secret"}), ErrBeneficiaryNotFound)
+}
+
+func TestChallengePayment(t *testing.T) {
+	ctx := context.Background()
+	s, beneficiaries, wallet := newBeneficiaryService(0)
+	sender := uuid.New()
+	payment := func(amount int64, proof *StepUp) *InitiatePaymentRequest {
+		return &InitiatePaymentRequest{SenderID: sender, Amount: decimal.NewFromInt(amount), StepUp: proof}
+	}
+
+	assert.NoError(t, s.challengePayment(ctx, payment(500, nil), wallet), "under the threshold")
+	assert.ErrorIs(t, s.challengePayment(ctx, payment(15000, nil), wallet), ErrStepUpRequired, "over the threshold")
+	assert.ErrorIs(t, s.challengePayment(ctx, payment(15000, &StepUp{Password: "wrong"}), wallet), ErrStepUpFailed)
+	assert.NoError(t, s.challengePayment(ctx, payment(15000, &StepUp{Password: "secret"}), wallet))
+	assert.NoError(t, s.challengePayment(ctx, payment(15000, nil), &domain.Wallet{ID: uuid.New(), UserID: sender}), "to the sender's own wallet")
+
+	_, err := s.AddTrustedBeneficiary(ctx, sender, "1234567890", "", &StepUp{Password: "secret"})
+	require.NoError(t, err)
+	assert.NoError(t, s.challengePayment(ctx, payment(15000, nil), wallet), "trusted and under the trusted maximum")
+	assert.ErrorIs(t, s.challengePayment(ctx, payment(25000, nil), wallet), ErrStepUpRequired, "over the trusted maximum")
+
+	beneficiaries.items[0].ActiveFrom = time.Now().Add(time.Hour)
+	assert.ErrorIs(t, s.challengePayment(ctx, payment(15000, nil), wallet), ErrStepUpRequired, "still cooling off")
+}
+
+func TestBlocksPayment(t *testing.T) {
+	for severity, blocked := range map[string]bool{"LOW": false, "MEDIUM": false, "HIGH": true, "CRITICAL": true} {
+		assert.Equal(t, blocked, blocksPayment(monitoring.Anomaly{Severity: severity}), severity)
+	}
+}
@@ -0,0 +1,654 @@
+// Package paymentbatch pays files of payments a customer uploads, such as
+// a payroll, in the background, keeping the outcome of every item.
+package paymentbatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidFile     = errors.New("invalid payment batch file")
+	ErrNotFound        = errors.New("payment batch not found")
+	ErrReferenceExists = errors.New("a payment batch with this reference already exists")
+)
+
+// Repository persists batches and their items.
+type Repository interface {
+	// CreateBatch stores a batch with all its items at once. It returns
+	// ErrReferenceExists when the user already has a batch with its
+	// reference.
+	CreateBatch(ctx context.Context, b *domain.PaymentBatch, items []domain.PaymentBatchItem) error
+	GetBatch(ctx context.Context, id uuid.UUID) (*domain.PaymentBatch, error)
+	ListBatches(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.PaymentBatch, int, error)
+	// ListItems pages through a batch's items by line, optionally only
+	// those with status.
+	ListItems(ctx context.Context, batchID uuid.UUID, status domain.PaymentBatchItemStatus, limit, offset int) ([]domain.PaymentBatchItem, int, error)
+	// ClaimBatch moves the oldest queued batch to processing and returns
+	// it, or nil when there is none, so concurrent workers never pick the
+	// same one. Batches left in processing by a worker that died are
+	// reclaimed after a lease.
+	ClaimBatch(ctx context.Context, now time.Time) (*domain.PaymentBatch, error)
+	PendingItems(ctx context.Context, batchID uuid.UUID, limit int) ([]domain.PaymentBatchItem, error)
+	// FinishItem records a pending item's outcome and counts it on its batch.
+	FinishItem(ctx context.Context, item *domain.PaymentBatchItem) error
+	CompleteBatch(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// Users looks up the receivers of a batch.
+type Users interface {
+	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*domain.User, error)
+}
+
+// Wallets finds the sender's wallet in an item's currency.
+type Wallets interface {
+	FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error)
+}
+
+// Payment is one item of a batch to pay.
+type Payment struct {
+	SenderID            uuid.UUID
+	ReceiverID          uuid.UUID
+	Amount              decimal.Decimal
+	Currency            domain.Currency
+	DestinationCurrency domain.Currency
+	Description         string
+	// Reference is unique to the item, so paying it again after an
+	// interruption returns the payment already made.
+	Reference string
+	Metadata  domain.Metadata
+}
+
+// Payer makes the payments, with every check a payment made one at a time
+// goes through.
+type Payer interface {
+	Pay(ctx context.Context, p *Payment) (*domain.Transaction, error)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
+// EventBatchCompleted tells the sender a batch has been processed.
+const EventBatchCompleted = "PAYMENT_BATCH_COMPLETED"
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultMaxItems     = 1000
+	itemBatchSize       = 100
+	maxReference        = 64
+	maxDescription      = 255
+)
+
+// columns are the CSV columns in the order validation errors are reported.
+var columns = []struct {
+	name     string
+	required bool
+}{
+	{"receiver_id", true},
+	{"amount", true},
+	{"currency", true},
+	{"destination_currency", false},
+	{"description", false},
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type Service struct {
+	repo     Repository
+	users    Users
+	wallets  Wallets
+	payer    Payer
+	notifier Notifier
+	logger   logger.Logger
+	maxItems int
+	interval time.Duration
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, users Users, wallets Wallets, payer Payer, notifier Notifier, cfg config.PaymentBatchConfig, log logger.Logger) *Service {
+	s := &Service{
+		repo:     repo,
+		users:    users,
+		wallets:  wallets,
+		payer:    payer,
+		notifier: notifier,
+		logger:   log,
+		maxItems: defaultMaxItems,
+		interval: defaultPollInterval,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+	if cfg.MaxItems > 0 {
+		s.maxItems = cfg.MaxItems
+	}
+	if cfg.PollInterval > 0 {
+		s.interval = cfg.PollInterval
+	}
+	return s
+}
+
+// UploadRequest is a file of payments from UserID.
+type UploadRequest struct {
+	UserID   uuid.UUID
+	FileName string
+	// Reference is the sender's own name for the batch. A second batch with
+	// the same reference is refused.
+	Reference string
+	Content   io.Reader
+}
+
+// Upload validates every item of a CSV or JSON file and queues the valid
+// ones for the worker. Items that fail validation are kept with their
+// errors and never paid; only a file that cannot be read at all is
+// rejected as a whole.
+func (s *Service) Upload(ctx context.Context, req *UploadRequest) (*domain.PaymentBatch, []domain.PaymentBatchItem, error) {
+	reference := strings.TrimSpace(req.Reference)
+	if len(reference) > maxReference {
+		return nil, nil, fmt.Errorf("%w: the reference may have at most %d characters", ErrInvalidFile, maxReference)
+	}
+	data, err := io.ReadAll(req.Content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	format := detect(req.FileName, data)
+	var (
+		records []domain.PaymentBatchRecord
+		lines   []int
+	)
+	if format == FormatJSON {
+		records, lines, err = s.parseJSON(data)
+	} else {
+		records, lines, err = s.parseCSV(data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := s.now()
+	b := &domain.PaymentBatch{
+		ID:         uuid.New(),
+		UserID:     req.UserID,
+		FileName:   strings.TrimSpace(req.FileName),
+		Format:     format,
+		Reference:  reference,
+		Status:     domain.PaymentBatchQueued,
+		TotalItems: len(records),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	items := make([]domain.PaymentBatchItem, len(records))
+	for i := range records {
+		items[i] = domain.PaymentBatchItem{BatchID: b.ID, Line: lines[i], Record: records[i]}
+		items[i].Errors = validate(&items[i].Record)
+	}
+	if err := s.checkParties(ctx, req.UserID, items); err != nil {
+		return nil, nil, err
+	}
+	for i := range items {
+		if len(items[i].Errors) > 0 {
+			items[i].Status = domain.PaymentBatchItemInvalid
+			b.InvalidItems++
+		} else {
+			items[i].Status = domain.PaymentBatchItemPending
+		}
+	}
+
+	if b.ValidItems() == 0 {
+		b.Status = domain.PaymentBatchCompleted
+		b.CompletedAt = &now
+	}
+	if err := s.repo.CreateBatch(ctx, b, items); err != nil {
+		return nil, nil, err
+	}
+	s.logger.Info("Payment batch queued", map[string]interface{}{
+		"batch_id":      b.ID,
+		"user_id":       b.UserID,
+		"total_items":   b.TotalItems,
+		"invalid_items": b.InvalidItems,
+	})
+	return b, items, nil
+}
+
+// detect picks the format from the file name, or from the content when the
+// extension does not say.
+func detect(name string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".csv":
+		return FormatCSV
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n\ufeff"), []byte("{")) {
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// parseCSV reads the records of a CSV with a header row naming its columns,
+// and the line each starts on.
+func (s *Service) parseCSV(data []byte) ([]domain.PaymentBatchRecord, []int, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("%w: the file is empty", ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, c := range columns {
+			if c.name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, nil, fmt.Errorf("%w: unknown column %q", ErrInvalidFile, name)
+		}
+		if _, dup := index[name]; dup {
+			return nil, nil, fmt.Errorf("%w: column %q appears twice", ErrInvalidFile, name)
+		}
+		index[name] = i
+	}
+	for _, c := range columns {
+		if _, ok := index[c.name]; c.required && !ok {
+			return nil, nil, fmt.Errorf("%w: missing column %q", ErrInvalidFile, c.name)
+		}
+	}
+
+	var (
+		records []domain.PaymentBatchRecord
+		lines   []int
+	)
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		if len(records) == s.maxItems {
+			return nil, nil, fmt.Errorf("%w: more than %d payments, split the file", ErrInvalidFile, s.maxItems)
+		}
+		line, _ := r.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := index[name]; ok && i < len(fields) {
+				return fields[i]
+			}
+			return ""
+		}
+		records = append(records, domain.PaymentBatchRecord{
+			ReceiverID:          get("receiver_id"),
+			Amount:              get("amount"),
+			Currency:            get("currency"),
+			DestinationCurrency: get("destination_currency"),
+			Description:         get("description"),
+		})
+		lines = append(lines, line)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%w: the file has no payments", ErrInvalidFile)
+	}
+	return records, lines, nil
+}
+
+// parseJSON reads the payments of a file shaped like the body of POST
+// /payments/bulk. An item's line is its 1-based position.
+func (s *Service) parseJSON(data []byte) ([]domain.PaymentBatchRecord, []int, error) {
+	var doc struct {
+		Payments []struct {
+			ReceiverID          string      `json:"receiver_id"`
+			Amount              json.Number `json:"amount"`
+			Currency            string      `json:"currency"`
+			DestinationCurrency string      `json:"destination_currency"`
+			Description         string      `json:"description"`
+		} `json:"payments"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	if len(doc.Payments) == 0 {
+		return nil, nil, fmt.Errorf("%w: the file has no payments", ErrInvalidFile)
+	}
+	if len(doc.Payments) > s.maxItems {
+		return nil, nil, fmt.Errorf("%w: more than %d payments, split the file", ErrInvalidFile, s.maxItems)
+	}
+	records := make([]domain.PaymentBatchRecord, len(doc.Payments))
+	lines := make([]int, len(doc.Payments))
+	for i, p := range doc.Payments {
+		records[i] = domain.PaymentBatchRecord{
+			ReceiverID:          p.ReceiverID,
+			Amount:              string(p.Amount),
+			Currency:            p.Currency,
+			DestinationCurrency: p.DestinationCurrency,
+			Description:         p.Description,
+		}
+		lines[i] = i + 1
+	}
+	return records, lines, nil
+}
+
+// validate tidies a record and reports its field errors in column order.
+func validate(rec *domain.PaymentBatchRecord) domain.PaymentBatchErrors {
+	rec.ReceiverID = strings.TrimSpace(rec.ReceiverID)
+	rec.Amount = strings.TrimSpace(rec.Amount)
+	rec.Currency = strings.ToUpper(strings.TrimSpace(rec.Currency))
+	rec.DestinationCurrency = strings.ToUpper(strings.TrimSpace(rec.DestinationCurrency))
+	rec.Description = strings.TrimSpace(rec.Description)
+
+	var errs domain.PaymentBatchErrors
+	fail := func(field, msg string) {
+		errs = append(errs, domain.PaymentBatchFieldError{Field: field, Message: msg})
+	}
+	if id, err := uuid.Parse(rec.ReceiverID); err != nil || id == uuid.Nil {
+		fail("receiver_id", "must be a user ID")
+	}
+	if amt, err := decimal.NewFromString(rec.Amount); err != nil {
+		fail("amount", "must be a decimal number")
+	} else if !amt.IsPositive() {
+		fail("amount", "must be greater than zero")
+	} else if !amt.Equal(amt.Round(2)) {
+		fail("amount", "must have at most two decimal places")
+	}
+	if !currencyCode.MatchString(rec.Currency) {
+		fail("currency", "must be an ISO 4217 code")
+	}
+	if rec.DestinationCurrency != "" && !currencyCode.MatchString(rec.DestinationCurrency) {
+		fail("destination_currency", "must be an ISO 4217 code")
+	}
+	if len(rec.Description) > maxDescription {
+		fail("description", fmt.Sprintf("must be at most %d characters", maxDescription))
+	}
+	return errs
+}
+
+// checkParties adds the errors of items whose receiver is not an active
+// user, or whose currency the sender has no wallet in. Receivers are looked
+// up all at once.
+func (s *Service) checkParties(ctx context.Context, senderID uuid.UUID, items []domain.PaymentBatchItem) error {
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for i := range items {
+		if len(items[i].Errors) > 0 {
+			continue
+		}
+		id := uuid.MustParse(items[i].Record.ReceiverID)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	users, err := s.users.FindByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	active := make(map[uuid.UUID]bool, len(users))
+	for _, u := range users {
+		active[u.ID] = u.IsActive
+	}
+
+	wallets := make(map[string]bool)
+	for i := range items {
+		item := &items[i]
+		if len(item.Errors) > 0 {
+			continue
+		}
+		id := uuid.MustParse(item.Record.ReceiverID)
+		switch isActive, found := active[id]; {
+		case !found:
+			item.Errors = append(item.Errors, domain.PaymentBatchFieldError{Field: "receiver_id", Message: "no such user"})
+		case !isActive:
+			item.Errors = append(item.Errors, domain.PaymentBatchFieldError{Field: "receiver_id", Message: "the receiver's account is not active"})
+		case id == senderID && (item.Record.DestinationCurrency == "" || item.Record.DestinationCurrency == item.Record.Currency):
+			item.Errors = append(item.Errors, domain.PaymentBatchFieldError{Field: "receiver_id", Message: "must be someone else"})
+		}
+
+		has, ok := wallets[item.Record.Currency]
+		if !ok {
+			w, err := s.wallets.FindByUserAndCurrency(ctx, senderID, domain.Currency(item.Record.Currency))
+			if err != nil {
+				return err
+			}
+			has = w != nil
+			wallets[item.Record.Currency] = has
+		}
+		if !has {
+			item.Errors = append(item.Errors, domain.PaymentBatchFieldError{Field: "currency", Message: "you have no wallet in this currency"})
+		}
+	}
+	return nil
+}
+
+// Get returns one of the user's batches.
+func (s *Service) Get(ctx context.Context, userID, id uuid.UUID) (*domain.PaymentBatch, error) {
+	b, err := s.repo.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if b.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// List returns the user's batches, newest first.
+func (s *Service) List(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.PaymentBatch, int, error) {
+	return s.repo.ListBatches(ctx, userID, limit, offset)
+}
+
+// Items returns a batch's per-item results, optionally only those with
+// status.
+func (s *Service) Items(ctx context.Context, userID, id uuid.UUID, status domain.PaymentBatchItemStatus, limit, offset int) ([]domain.PaymentBatchItem, int, error) {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListItems(ctx, id, status, limit, offset)
+}
+
+// reportHeader names the columns of a batch report.
+var reportHeader = []string{
+	"line", "receiver_id", "amount", "currency", "destination_currency", "description",
+	"status", "transaction_id", "errors",
+}
+
+// Report writes the outcome of every item of one of the user's batches as
+// CSV, in file order. Items still pending show as such.
+func (s *Service) Report(ctx context.Context, userID, id uuid.UUID, w io.Writer) (*domain.PaymentBatch, error) {
+	b, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(reportHeader); err != nil {
+		return nil, err
+	}
+	for offset := 0; ; offset += itemBatchSize {
+		items, _, err := s.repo.ListItems(ctx, id, "", itemBatchSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			txID := ""
+			if item.TransactionID != nil {
+				txID = item.TransactionID.String()
+			}
+			msgs := make([]string, len(item.Errors))
+			for i, e := range item.Errors {
+				msgs[i] = e.Message
+				if e.Field != "" {
+					msgs[i] = e.Field + ": " + e.Message
+				}
+			}
+			rec := item.Record
+			if err := cw.Write([]string{
+				strconv.Itoa(item.Line), rec.ReceiverID, rec.Amount, rec.Currency, rec.DestinationCurrency, rec.Description,
+				string(item.Status), txID, strings.Join(msgs, "; "),
+			}); err != nil {
+				return nil, err
+			}
+		}
+		if len(items) < itemBatchSize {
+			break
+		}
+	}
+	cw.Flush()
+	return b, cw.Error()
+}
+
+// Start runs the batch worker until Stop is called.
+func (s *Service) Start() {
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.RunDue(context.Background())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Payment batch worker started", map[string]interface{}{"interval": s.interval.String()})
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// RunDue processes queued batches one after another until none is left.
+func (s *Service) RunDue(ctx context.Context) {
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		b, err := s.repo.ClaimBatch(ctx, s.now())
+		if err != nil {
+			s.logger.Error("Failed to claim payment batch", map[string]interface{}{"error": err.Error()})
+			return
+		}
+		if b == nil {
+			return
+		}
+		if err := s.process(ctx, b); err != nil {
+			// The batch stays in processing; finished items are recorded,
+			// and each item's reference makes paying it again return the
+			// payment already made, so it resumes safely once reclaimed.
+			s.logger.Error("Payment batch interrupted", map[string]interface{}{
+				"error":    err.Error(),
+				"batch_id": b.ID,
+			})
+			return
+		}
+	}
+}
+
+func (s *Service) process(ctx context.Context, b *domain.PaymentBatch) error {
+	for {
+		items, err := s.repo.PendingItems(ctx, b.ID, itemBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for i := range items {
+			s.pay(ctx, b, &items[i])
+			if err := s.repo.FinishItem(ctx, &items[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.repo.CompleteBatch(ctx, b.ID, s.now()); err != nil {
+		return err
+	}
+	done, err := s.repo.GetBatch(ctx, b.ID)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("Payment batch completed", map[string]interface{}{
+		"batch_id":  b.ID,
+		"succeeded": done.SucceededItems,
+		"failed":    done.FailedItems,
+	})
+	name := done.Reference
+	if name == "" {
+		name = done.FileName
+	}
+	if err := s.notifier.Notify(ctx, done.UserID, EventBatchCompleted, map[string]interface{}{
+		"batch_id":  done.ID.String(),
+		"name":      name,
+		"succeeded": done.SucceededItems,
+		"failed":    done.FailedItems + done.InvalidItems,
+	}); err != nil {
+		s.logger.Warn("Failed to notify payment batch completion", map[string]interface{}{"error": err.Error(), "batch_id": b.ID})
+	}
+	return nil
+}
+
+// pay makes the payment of a pending item and sets the item's outcome.
+func (s *Service) pay(ctx context.Context, b *domain.PaymentBatch, item *domain.PaymentBatchItem) {
+	rec := item.Record
+	amount, _ := decimal.NewFromString(rec.Amount)
+	tx, err := s.payer.Pay(ctx, &Payment{
+		SenderID:            b.UserID,
+		ReceiverID:          uuid.MustParse(rec.ReceiverID),
+		Amount:              amount,
+		Currency:            domain.Currency(rec.Currency),
+		DestinationCurrency: domain.Currency(rec.DestinationCurrency),
+		Description:         rec.Description,
+		Reference:           fmt.Sprintf("BATCH-%s-%d", b.ID, item.Line),
+		Metadata:            domain.Metadata{"payment_batch_id": b.ID.String(), "payment_batch_line": item.Line},
+	})
+
+	now := s.now()
+	item.ProcessedAt = &now
+	if err != nil {
+		item.Status = domain.PaymentBatchItemFailed
+		item.Errors = domain.PaymentBatchErrors{{Message: err.Error()}}
+		return
+	}
+	item.Status = domain.PaymentBatchItemSucceeded
+	item.TransactionID = &tx.ID
+}
@@ -0,0 +1,439 @@
+package paymentbatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateBatch(ctx context.Context, b *domain.PaymentBatch, items []domain.PaymentBatchItem) error {
+	return m.Called(ctx, b, items).Error(0)
+}
+
+func (m *MockRepository) GetBatch(ctx context.Context, id uuid.UUID) (*domain.PaymentBatch, error) {
+	args := m.Called(ctx, id)
+	b, _ := args.Get(0).(*domain.PaymentBatch)
+	if b == nil {
+		return nil, args.Error(1)
+	}
+	cp := *b
+	return &cp, args.Error(1)
+}
+
+func (m *MockRepository) ListBatches(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.PaymentBatch, int, error) {
+	args := m.Called(ctx, userID, limit, offset)
+	batches, _ := args.Get(0).([]domain.PaymentBatch)
+	return batches, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) ListItems(ctx context.Context, batchID uuid.UUID, status domain.PaymentBatchItemStatus, limit, offset int) ([]domain.PaymentBatchItem, int, error) {
+	args := m.Called(ctx, batchID, status, limit, offset)
+	items, _ := args.Get(0).([]domain.PaymentBatchItem)
+	return items, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) ClaimBatch(ctx context.Context, now time.Time) (*domain.PaymentBatch, error) {
+	args := m.Called(ctx, now)
+	b, _ := args.Get(0).(*domain.PaymentBatch)
+	return b, args.Error(1)
+}
+
+func (m *MockRepository) PendingItems(ctx context.Context, batchID uuid.UUID, limit int) ([]domain.PaymentBatchItem, error) {
+	args := m.Called(ctx, batchID, limit)
+	items, _ := args.Get(0).([]domain.PaymentBatchItem)
+	return items, args.Error(1)
+}
+
+func (m *MockRepository) FinishItem(ctx context.Context, item *domain.PaymentBatchItem) error {
+	return m.Called(ctx, item).Error(0)
+}
+
+func (m *MockRepository) CompleteBatch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return m.Called(ctx, id, at).Error(0)
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*domain.User, error) {
+	args := m.Called(ctx, ids)
+	users, _ := args.Get(0).([]*domain.User)
+	return users, args.Error(1)
+}
+
+type MockWallets struct {
+	mock.Mock
+}
+
+func (m *MockWallets) FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error) {
+	args := m.Called(ctx, userID, currency)
+	w, _ := args.Get(0).(*domain.Wallet)
+	return w, args.Error(1)
+}
+
+type MockPayer struct {
+	mock.Mock
+}
+
+func (m *MockPayer) Pay(ctx context.Context, p *Payment) (*domain.Transaction, error) {
+	args := m.Called(ctx, p)
+	tx, _ := args.Get(0).(*domain.Transaction)
+	return tx, args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
+	return m.Called(ctx, userID, eventType, data).Error(0)
+}
+
+type mocks struct {
+	repo     *MockRepository
+	users    *MockUsers
+	wallets  *MockWallets
+	payer    *MockPayer
+	notifier *MockNotifier
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.users.AssertExpectations(t)
+	m.wallets.AssertExpectations(t)
+	m.payer.AssertExpectations(t)
+	m.notifier.AssertExpectations(t)
+}
+
+var (
+	ctx      = context.Background()
+	start    = time.Date(2026, 5, 29, 9, 0, 0, 0, time.UTC)
+	sender   = &domain.User{ID: uuid.New(), IsActive: true}
+	alice    = &domain.User{ID: uuid.New(), IsActive: true}
+	bob      = &domain.User{ID: uuid.New(), IsActive: true}
+	inactive = &domain.User{ID: uuid.New()}
+	mwk      = &domain.Wallet{ID: uuid.New(), UserID: sender.ID, Currency: domain.MWK}
+)
+
+func newService(now *time.Time, cfg config.PaymentBatchConfig) (*Service, *mocks) {
+	m := &mocks{new(MockRepository), new(MockUsers), new(MockWallets), new(MockPayer), new(MockNotifier)}
+	s := NewService(m.repo, m.users, m.wallets, m.payer, m.notifier, cfg, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, m
+}
+
+// ids matches a lookup of exactly these users, in any order.
+func ids(want ...uuid.UUID) interface{} {
+	return mock.MatchedBy(func(got []uuid.UUID) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		seen := make(map[uuid.UUID]bool, len(got))
+		for _, id := range got {
+			seen[id] = true
+		}
+		for _, id := range want {
+			if !seen[id] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// batch is a queued batch of the sender's.
+func batch(reference string) *domain.PaymentBatch {
+	return &domain.PaymentBatch{ID: uuid.New(), UserID: sender.ID, FileName: "payroll.csv", Format: FormatCSV, Reference: reference, Status: domain.PaymentBatchQueued, CreatedAt: start}
+}
+
+func pending(b *domain.PaymentBatch, line int, receiver *domain.User, amount string) domain.PaymentBatchItem {
+	return domain.PaymentBatchItem{
+		BatchID: b.ID,
+		Line:    line,
+		Record:  domain.PaymentBatchRecord{ReceiverID: receiver.ID.String(), Amount: amount, Currency: "MWK"},
+		Status:  domain.PaymentBatchItemPending,
+	}
+}
+
+func TestUploadValidatesEveryItem(t *testing.T) {
+	now := start
+	s, m := newService(&now, config.PaymentBatchConfig{})
+	unknown := uuid.New()
+	m.users.On("FindByIDs", ctx, ids(alice.ID, unknown, inactive.ID, bob.ID, sender.ID)).
+		Return([]*domain.User{alice, bob, inactive, sender}, nil).Once()
+	m.wallets.On("FindByUserAndCurrency", ctx, sender.ID, domain.MWK).Return(mwk, nil).Once()
+	m.wallets.On("FindByUserAndCurrency", ctx, sender.ID, domain.ZAR).Return(nil, nil).Once()
+	m.repo.On("CreateBatch", ctx, mock.MatchedBy(func(b *domain.PaymentBatch) bool {
+		return b.UserID == sender.ID && b.CreatedAt.Equal(now)
+	}), mock.MatchedBy(func(items []domain.PaymentBatchItem) bool {
+		return len(items) == 9
+	})).Return(nil).Once()
+
+	b, items, err := s.Upload(ctx, &UploadRequest{UserID: sender.ID, FileName: "payroll.csv", Content: strings.NewReader(fmt.Sprintf(`receiver_id,amount,currency,description
+%[1]s,150.00,mwk,Salary
+not-an-id,10,MWK,
+%[1]s,-5,MWK,
+%[1]s,1.005,MWK,
+%[2]s,10,MWK,
+%[3]s,10,MWK,
+%[1]s,10,ZAR,
+%[4]s,20,MWK,
+%[5]s,30,MWK,
+`, alice.ID, unknown, inactive.ID, bob.ID, sender.ID))})
+	require.NoError(t, err)
+	m.assert(t)
+
+	assert.Equal(t, FormatCSV, b.Format)
+	assert.Equal(t, domain.PaymentBatchQueued, b.Status)
+	assert.Equal(t, 9, b.TotalItems)
+	assert.Equal(t, 7, b.InvalidItems)
+	assert.Equal(t, 2, b.ValidItems())
+
+	require.Len(t, items, 9)
+	assert.Equal(t, 2, items[0].Line, "the header is line 1")
+	assert.Equal(t, domain.PaymentBatchItemPending, items[0].Status)
+	assert.Equal(t, "MWK", items[0].Record.Currency)
+	errs := map[int]string{}
+	for _, item := range items[1:] {
+		if item.Status == domain.PaymentBatchItemInvalid {
+			require.NotEmpty(t, item.Errors)
+			errs[item.Line] = item.Errors[0].Field + ": " + item.Errors[0].Message
+		}
+	}
+	assert.Equal(t, map[int]string{
+		3:  "receiver_id: must be a user ID",
+		4:  "amount: must be greater than zero",
+		5:  "amount: must have at most two decimal places",
+		6:  "receiver_id: no such user",
+		7:  "receiver_id: the receiver's account is not active",
+		8:  "currency: you have no wallet in this currency",
+		10: "receiver_id: must be someone else",
+	}, errs)
+	assert.Equal(t, domain.PaymentBatchItemPending, items[7].Status)
+}
+
+func TestUploadJSON(t *testing.T) {
+	now := start
+	s, m := newService(&now, config.PaymentBatchConfig{})
+	m.users.On("FindByIDs", ctx, ids(alice.ID)).Return([]*domain.User{alice}, nil).Once()
+	m.wallets.On("FindByUserAndCurrency", ctx, sender.ID, domain.MWK).Return(mwk, nil).Once()
+	m.repo.On("CreateBatch", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+
+	b, items, err := s.Upload(ctx, &UploadRequest{UserID: sender.ID, Content: strings.NewReader(fmt.Sprintf(`{"payments": [
+		{"receiver_id": %q, "amount": 150.5, "currency": "MWK", "description": "Salary"},
+		{"receiver_id": "nobody", "amount": 1, "currency": "MWK"}
+	]}`, alice.ID))})
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, b.Format, "detected from the content")
+	assert.Equal(t, "150.5", items[0].Record.Amount, "as written")
+	assert.Equal(t, 2, items[1].Line, "the position in the list")
+	m.assert(t)
+}
+
+func TestUploadRejectsUnreadableFiles(t *testing.T) {
+	now := start
+	id := uuid.New().String()
+	tests := []struct {
+		name, reference, content string
+	}{
+		{name: "empty"},
+		{name: "no payments", content: "receiver_id,amount,currency\n"},
+		{name: "missing column", content: "receiver_id,amount\n" + id + ",1\n"},
+		{name: "unknown column", content: "receiver_id,amount,currency,iban\n" + id + ",1,MWK,x\n"},
+		{name: "duplicate column", content: "receiver_id,amount,currency,amount\n" + id + ",1,MWK,2\n"},
+		{name: "too many", content: "receiver_id,amount,currency\n" + strings.Repeat(id+",1,MWK\n", 3)},
+		{name: "bad json", content: `{"payments": [{"receiver": "x"}]}`},
+		{name: "long reference", reference: strings.Repeat("R", maxReference+1), content: "receiver_id,amount,currency\n" + id + ",1,MWK\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m := newService(&now, config.PaymentBatchConfig{MaxItems: 2})
+			_, _, err := s.Upload(ctx, &UploadRequest{UserID: sender.ID, Reference: tt.reference, Content: strings.NewReader(tt.content)})
+			assert.ErrorIs(t, err, ErrInvalidFile)
+			m.users.AssertNotCalled(t, "FindByIDs", mock.Anything, mock.Anything)
+			m.repo.AssertNotCalled(t, "CreateBatch", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("reference taken", func(t *testing.T) {
+		s, m := newService(&now, config.PaymentBatchConfig{})
+		m.users.On("FindByIDs", ctx, ids(alice.ID)).Return([]*domain.User{alice}, nil).Once()
+		m.wallets.On("FindByUserAndCurrency", ctx, sender.ID, domain.MWK).Return(mwk, nil).Once()
+		m.repo.On("CreateBatch", ctx, mock.MatchedBy(func(b *domain.PaymentBatch) bool {
+			return b.Reference == "MAY"
+		}), mock.Anything).Return(ErrReferenceExists).Once()
+
+		_, _, err := s.Upload(ctx, &UploadRequest{UserID: sender.ID, Reference: " MAY ", Content: strings.NewReader("receiver_id,amount,currency\n" + alice.ID.String() + ",1,MWK\n")})
+		assert.ErrorIs(t, err, ErrReferenceExists)
+		m.assert(t)
+	})
+}
+
+func TestUploadWithNothingValidIsComplete(t *testing.T) {
+	now := start
+	s, m := newService(&now, config.PaymentBatchConfig{})
+	m.users.On("FindByIDs", ctx, ([]uuid.UUID)(nil)).Return(nil, nil).Once()
+	m.repo.On("CreateBatch", ctx, mock.MatchedBy(func(b *domain.PaymentBatch) bool {
+		return b.Status == domain.PaymentBatchCompleted && b.CompletedAt.Equal(now)
+	}), mock.Anything).Return(nil).Once()
+
+	_, _, err := s.Upload(ctx, &UploadRequest{UserID: sender.ID, FileName: "a.csv", Content: strings.NewReader("receiver_id,amount,currency\nnobody,1,MWK\n")})
+	require.NoError(t, err)
+	m.assert(t)
+}
+
+func TestRunDuePaysValidItems(t *testing.T) {
+	now := start
+	s, m := newService(&now, config.PaymentBatchConfig{})
+	b := batch("MAY")
+	items := []domain.PaymentBatchItem{pending(b, 1, alice, "150.5"), pending(b, 2, bob, "75")}
+	items[0].Record.Description = "Salary"
+	tx := &domain.Transaction{ID: uuid.New()}
+	done := *b
+	done.Status, done.ProcessedItems, done.SucceededItems, done.FailedItems, done.InvalidItems = domain.PaymentBatchCompleted, 2, 1, 1, 1
+
+	m.repo.On("ClaimBatch", ctx, now).Return(b, nil).Once()
+	m.repo.On("PendingItems", ctx, b.ID, itemBatchSize).Return(items, nil).Once()
+	m.payer.On("Pay", ctx, mock.MatchedBy(func(p *Payment) bool {
+		return p.SenderID == sender.ID && p.ReceiverID == alice.ID && p.Amount.String() == "150.5" &&
+			p.Currency == domain.MWK && p.Description == "Salary" &&
+			p.Reference == fmt.Sprintf("BATCH-%s-1", b.ID) && p.Metadata["payment_batch_line"] == 1
+	})).Return(tx, nil).Once()
+	m.payer.On("Pay", ctx, mock.MatchedBy(func(p *Payment) bool {
+		return p.ReceiverID == bob.ID
+	})).Return(nil, errors.New("insufficient balance")).Once()
+	m.repo.On("FinishItem", ctx, mock.MatchedBy(func(item *domain.PaymentBatchItem) bool {
+		return item.Line == 1 && item.Status == domain.PaymentBatchItemSucceeded && *item.TransactionID == tx.ID && item.ProcessedAt.Equal(now)
+	})).Return(nil).Once()
+	m.repo.On("FinishItem", ctx, mock.MatchedBy(func(item *domain.PaymentBatchItem) bool {
+		return item.Line == 2 && item.Status == domain.PaymentBatchItemFailed && item.TransactionID == nil &&
+			item.Errors[0].Message == "insufficient balance"
+	})).Return(nil).Once()
+	m.repo.On("PendingItems", ctx, b.ID, itemBatchSize).Return(nil, nil).Once()
+	m.repo.On("CompleteBatch", ctx, b.ID, now).Return(nil).Once()
+	m.repo.On("GetBatch", ctx, b.ID).Return(&done, nil).Once()
+	m.notifier.On("Notify", ctx, sender.ID, EventBatchCompleted, mock.MatchedBy(func(data map[string]interface{}) bool {
+		return data["name"] == "MAY" && data["succeeded"] == 1 && data["failed"] == 2
+	})).Return(nil).Once()
+	m.repo.On("ClaimBatch", ctx, now).Return(nil, nil).Once()
+
+	s.RunDue(ctx)
+	m.assert(t)
+}
+
+func TestRunDueStopsWhenInterrupted(t *testing.T) {
+	now := start
+	b := batch("")
+
+	t.Run("an item that cannot be recorded", func(t *testing.T) {
+		s, m := newService(&now, config.PaymentBatchConfig{})
+		m.repo.On("ClaimBatch", ctx, now).Return(b, nil).Once()
+		m.repo.On("PendingItems", ctx, b.ID, itemBatchSize).Return([]domain.PaymentBatchItem{pending(b, 1, alice, "1"), pending(b, 2, bob, "1")}, nil).Once()
+		m.payer.On("Pay", ctx, mock.Anything).Return(&domain.Transaction{ID: uuid.New()}, nil).Once()
+		m.repo.On("FinishItem", ctx, mock.Anything).Return(errors.New("connection reset")).Once()
+
+		s.RunDue(ctx)
+		m.assert(t)
+		m.repo.AssertNotCalled(t, "CompleteBatch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no batch can be claimed", func(t *testing.T) {
+		s, m := newService(&now, config.PaymentBatchConfig{})
+		m.repo.On("ClaimBatch", ctx, now).Return(nil, errors.New("connection reset")).Once()
+
+		s.RunDue(ctx)
+		m.assert(t)
+	})
+
+	t.Run("the notification fails", func(t *testing.T) {
+		s, m := newService(&now, config.PaymentBatchConfig{})
+		done := *b
+		m.repo.On("ClaimBatch", ctx, now).Return(b, nil).Once()
+		m.repo.On("PendingItems", ctx, b.ID, itemBatchSize).Return(nil, nil).Once()
+		m.repo.On("CompleteBatch", ctx, b.ID, now).Return(nil).Once()
+		m.repo.On("GetBatch", ctx, b.ID).Return(&done, nil).Once()
+		m.notifier.On("Notify", ctx, sender.ID, EventBatchCompleted, mock.MatchedBy(func(data map[string]interface{}) bool {
+			return data["name"] == "payroll.csv"
+		})).Return(errors.New("smtp down")).Once()
+		m.repo.On("ClaimBatch", ctx, now).Return(nil, nil).Once()
+
+		s.RunDue(ctx)
+		m.assert(t)
+	})
+}
+
+func TestReport(t *testing.T) {
+	now := start
+	s, m := newService(&now, config.PaymentBatchConfig{})
+	b := batch("")
+	txID := uuid.New()
+	paid := pending(b, 1, alice, "150.5")
+	paid.Record.Description, paid.Status, paid.TransactionID = "Salary", domain.PaymentBatchItemSucceeded, &txID
+	failed := pending(b, 2, bob, "75")
+	failed.Status, failed.Errors = domain.PaymentBatchItemFailed, domain.PaymentBatchErrors{{Message: "insufficient balance"}}
+	invalid := domain.PaymentBatchItem{BatchID: b.ID, Line: 3, Record: domain.PaymentBatchRecord{ReceiverID: "nobody", Amount: "1", Currency: "MWK"},
+		Status: domain.PaymentBatchItemInvalid, Errors: domain.PaymentBatchErrors{{Field: "receiver_id", Message: "must be a user ID"}}}
+	page := make([]domain.PaymentBatchItem, itemBatchSize)
+	for i := range page {
+		page[i] = pending(b, i+1, alice, "1")
+	}
+	page[0], page[1], page[2] = paid, failed, invalid
+	m.repo.On("GetBatch", ctx, b.ID).Return(b, nil).Once()
+	m.repo.On("ListItems", ctx, b.ID, domain.PaymentBatchItemStatus(""), itemBatchSize, 0).Return(page, itemBatchSize+1, nil).Once()
+	m.repo.On("ListItems", ctx, b.ID, domain.PaymentBatchItemStatus(""), itemBatchSize, itemBatchSize).
+		Return([]domain.PaymentBatchItem{pending(b, itemBatchSize+1, bob, "1")}, itemBatchSize+1, nil).Once()
+
+	var buf bytes.Buffer
+	_, err := s.Report(ctx, sender.ID, b.ID, &buf)
+	require.NoError(t, err)
+	m.assert(t)
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, itemBatchSize+2, "read in pages")
+	assert.Equal(t, reportHeader, rows[0])
+	assert.Equal(t, []string{"1", alice.ID.String(), "150.5", "MWK", "", "Salary", "succeeded", txID.String(), ""}, rows[1])
+	assert.Equal(t, []string{"failed", "", "insufficient balance"}, rows[2][6:])
+	assert.Equal(t, []string{"invalid", "", "receiver_id: must be a user ID"}, rows[3][6:])
+	assert.Equal(t, "pending", rows[len(rows)-1][6])
+}
+
+func TestBatchesAreTheirOwnersOnly(t *testing.T) {
+	now := start
+	s, m := newService(&now, config.PaymentBatchConfig{})
+	b := batch("")
+	m.repo.On("GetBatch", ctx, b.ID).Return(b, nil)
+	m.repo.On("ListBatches", ctx, alice.ID, 10, 0).Return(nil, 0, nil).Once()
+
+	_, err := s.Get(ctx, alice.ID, b.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = s.Items(ctx, alice.ID, b.ID, "", 10, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Report(ctx, alice.ID, b.ID, &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	m.repo.AssertNotCalled(t, "ListItems", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	_, total, err := s.List(ctx, alice.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	m.assert(t)
+}
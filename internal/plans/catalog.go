@@ -0,0 +1,51 @@
+package plans
+
+import (
+	"kyd/internal/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCatalog is the plan line-up. Consumer matches the limits and fee
+// every account had before plans existed, so it is the default.
+func DefaultCatalog() map[domain.PlanCode]domain.Plan {
+	return map[domain.PlanCode]domain.Plan{
+		domain.PlanConsumer: {
+			Code:        domain.PlanConsumer,
+			Name:        "Consumer",
+			MonthlyFee:  decimal.Zero,
+			FeeCurrency: domain.MWK,
+			Entitlements: domain.Entitlements{
+				Plan:            domain.PlanConsumer,
+				LimitMultiplier: decimal.NewFromInt(1),
+				FeeRate:         decimal.NewFromFloat(0.015),
+			},
+		},
+		domain.PlanPremium: {
+			Code:        domain.PlanPremium,
+			Name:        "Premium",
+			MonthlyFee:  decimal.NewFromInt(5000),
+			FeeCurrency: domain.MWK,
+			Entitlements: domain.Entitlements{
+				Plan:               domain.PlanPremium,
+				LimitMultiplier:    decimal.NewFromInt(2),
+				FeeRate:            decimal.NewFromFloat(0.01),
+				PrioritySettlement: true,
+			},
+		},
+		domain.PlanBusiness: {
+			Code:         domain.PlanBusiness,
+			Name:         "Business",
+			MonthlyFee:   decimal.NewFromInt(25000),
+			FeeCurrency:  domain.MWK,
+			MerchantOnly: true,
+			Entitlements: domain.Entitlements{
+				Plan:               domain.PlanBusiness,
+				LimitMultiplier:    decimal.NewFromInt(5),
+				FeeRate:            decimal.NewFromFloat(0.0075),
+				PrioritySettlement: true,
+				APIAccess:          true,
+			},
+		},
+	}
+}
@@ -0,0 +1,254 @@
+// Package plans assigns product plans to accounts and answers what each
+// account is entitled to.
+package plans
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownPlan      = errors.New("unknown plan")
+	ErrPlanNotAvailable = errors.New("plan is not available for this account")
+)
+
+// Repository persists plan subscriptions.
+type Repository interface {
+	// GetSubscription returns the user's subscription, or nil when they have
+	// never changed plan.
+	GetSubscription(ctx context.Context, userID uuid.UUID) (*domain.PlanSubscription, error)
+	SaveSubscription(ctx context.Context, sub *domain.PlanSubscription) error
+}
+
+// UserRepository looks up the account a plan is for.
+type UserRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// ChangeHook is told about every plan change once it is saved, for example
+// to bill a proration. Errors are logged; the change stands.
+type ChangeHook interface {
+	PlanChanged(ctx context.Context, change *domain.PlanChange) error
+}
+
+type Service struct {
+	repo    Repository
+	users   UserRepository
+	catalog map[domain.PlanCode]domain.Plan
+	hooks   []ChangeHook
+	logger  logger.Logger
+	now     func() time.Time
+}
+
+func NewService(repo Repository, users UserRepository, log logger.Logger) *Service {
+	return &Service{
+		repo:    repo,
+		users:   users,
+		catalog: DefaultCatalog(),
+		logger:  log,
+		now:     time.Now,
+	}
+}
+
+// WithHook adds a hook that is told about plan changes.
+func (s *Service) WithHook(h ChangeHook) *Service {
+	s.hooks = append(s.hooks, h)
+	return s
+}
+
+// Plans lists the catalog, cheapest first.
+func (s *Service) Plans() []domain.Plan {
+	out := make([]domain.Plan, 0, len(s.catalog))
+	for _, p := range s.catalog {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].MonthlyFee.LessThan(out[j].MonthlyFee) })
+	return out
+}
+
+// Plan returns a plan from the catalog.
+func (s *Service) Plan(code domain.PlanCode) (domain.Plan, error) {
+	p, ok := s.catalog[code]
+	if !ok {
+		return domain.Plan{}, ErrUnknownPlan
+	}
+	return p, nil
+}
+
+// Subscription returns the user's subscription as it stands now: billing
+// periods that have ended are rolled forward and a downgrade due at their
+// end applied. Users who never changed plan are on the consumer plan.
+func (s *Service) Subscription(ctx context.Context, userID uuid.UUID) (*domain.PlanSubscription, error) {
+	now := s.now().UTC()
+	sub, err := s.repo.GetSubscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return &domain.PlanSubscription{
+			UserID:      userID,
+			Plan:        domain.PlanConsumer,
+			PeriodStart: now,
+			PeriodEnd:   now.AddDate(0, 1, 0),
+			UpdatedAt:   now,
+		}, nil
+	}
+	current := rollForward(*sub, now)
+	return &current, nil
+}
+
+// Entitlements is the central entitlement check: what the user's current
+// plan allows. Fees, limits, settlement routing and API access all use it.
+func (s *Service) Entitlements(ctx context.Context, userID uuid.UUID) (*domain.Entitlements, error) {
+	sub, err := s.Subscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := s.catalog[sub.Plan]
+	if !ok {
+		// A plan withdrawn from the catalog falls back to the default.
+		p = s.catalog[domain.PlanConsumer]
+	}
+	ent := p.Entitlements
+	return &ent, nil
+}
+
+// ChangePlan is the customer's own upgrade or downgrade. An upgrade applies
+// at once and is prorated for the rest of the billing period; a downgrade
+// waits for the period end, which the customer has already paid for.
+// Choosing the current plan again cancels a pending downgrade.
+func (s *Service) ChangePlan(ctx context.Context, userID uuid.UUID, to domain.PlanCode) (*domain.PlanSubscription, error) {
+	target, err := s.Plan(to)
+	if err != nil {
+		return nil, err
+	}
+	if target.MerchantOnly {
+		user, err := s.users.FindByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if user.UserType != domain.UserTypeMerchant {
+			return nil, ErrPlanNotAvailable
+		}
+	}
+	sub, err := s.Subscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+
+	if to == sub.Plan {
+		if sub.PendingPlan == nil {
+			return sub, nil
+		}
+		sub.PendingPlan = nil
+		sub.UpdatedAt = now
+		return sub, s.repo.SaveSubscription(ctx, sub)
+	}
+
+	from := s.catalog[sub.Plan]
+	change := &domain.PlanChange{
+		UserID:    userID,
+		From:      sub.Plan,
+		To:        to,
+		Proration: decimal.Zero,
+		Currency:  target.FeeCurrency,
+		ChangedBy: userID,
+	}
+	if target.MonthlyFee.GreaterThan(from.MonthlyFee) {
+		change.EffectiveAt = now
+		change.Proration = prorate(target.MonthlyFee.Sub(from.MonthlyFee), sub, now)
+		sub.Plan = to
+		sub.PendingPlan = nil
+	} else {
+		change.EffectiveAt = sub.PeriodEnd
+		sub.PendingPlan = &to
+	}
+	sub.UpdatedAt = now
+	if err := s.repo.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	s.planChanged(ctx, change)
+	return sub, nil
+}
+
+// AssignPlan puts a user on a plan at once with a new billing period,
+// without proration or the merchant-only restriction (admin).
+func (s *Service) AssignPlan(ctx context.Context, userID uuid.UUID, to domain.PlanCode, adminID uuid.UUID) (*domain.PlanSubscription, error) {
+	target, err := s.Plan(to)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.Subscription(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	sub := &domain.PlanSubscription{
+		UserID:      userID,
+		Plan:        to,
+		PeriodStart: now,
+		PeriodEnd:   now.AddDate(0, 1, 0),
+		UpdatedAt:   now,
+	}
+	if err := s.repo.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	s.planChanged(ctx, &domain.PlanChange{
+		UserID:      userID,
+		From:        current.Plan,
+		To:          to,
+		EffectiveAt: now,
+		Proration:   decimal.Zero,
+		Currency:    target.FeeCurrency,
+		ChangedBy:   adminID,
+	})
+	return sub, nil
+}
+
+func (s *Service) planChanged(ctx context.Context, change *domain.PlanChange) {
+	s.logger.Info("Plan changed", map[string]interface{}{
+		"user_id":      change.UserID,
+		"from":         change.From,
+		"to":           change.To,
+		"effective_at": change.EffectiveAt,
+		"proration":    change.Proration.String(),
+	})
+	for _, h := range s.hooks {
+		if err := h.PlanChanged(ctx, change); err != nil {
+			s.logger.Error("Plan change hook failed", map[string]interface{}{"user_id": change.UserID, "error": err.Error()})
+		}
+	}
+}
+
+// rollForward advances sub through the billing periods that ended before
+// now, applying a pending downgrade at the first of them.
+func rollForward(sub domain.PlanSubscription, now time.Time) domain.PlanSubscription {
+	for !now.Before(sub.PeriodEnd) {
+		if sub.PendingPlan != nil {
+			sub.Plan = *sub.PendingPlan
+			sub.PendingPlan = nil
+		}
+		sub.PeriodStart = sub.PeriodEnd
+		sub.PeriodEnd = sub.PeriodEnd.AddDate(0, 1, 0)
+	}
+	return sub
+}
+
+// prorate charges the monthly difference for the part of the period left.
+func prorate(monthlyDifference decimal.Decimal, sub *domain.PlanSubscription, now time.Time) decimal.Decimal {
+	period := sub.PeriodEnd.Sub(sub.PeriodStart)
+	left := sub.PeriodEnd.Sub(now)
+	if period <= 0 || left <= 0 {
+		return decimal.Zero
+	}
+	return monthlyDifference.Mul(decimal.NewFromInt(int64(left))).Div(decimal.NewFromInt(int64(period))).Round(2)
+}
@@ -0,0 +1,200 @@
+package plans
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	pkgerrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetSubscription(ctx context.Context, userID uuid.UUID) (*domain.PlanSubscription, error) {
+	args := m.Called(ctx, userID)
+	sub, _ := args.Get(0).(*domain.PlanSubscription)
+	if sub != nil {
+		cp := *sub
+		sub = &cp
+	}
+	return sub, args.Error(1)
+}
+
+func (m *MockRepository) SaveSubscription(ctx context.Context, sub *domain.PlanSubscription) error {
+	return m.Called(ctx, sub).Error(0)
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	user, _ := args.Get(0).(*domain.User)
+	return user, args.Error(1)
+}
+
+type MockHook struct {
+	mock.Mock
+}
+
+func (m *MockHook) PlanChanged(ctx context.Context, change *domain.PlanChange) error {
+	return m.Called(ctx, change).Error(0)
+}
+
+var (
+	ctx   = context.Background()
+	march = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *MockRepository, *MockUsers, *MockHook) {
+	repo, users, hook := &MockRepository{}, &MockUsers{}, &MockHook{}
+	s := NewService(repo, users, logger.NewNop()).WithHook(hook)
+	s.now = func() time.Time { return *now }
+	return s, repo, users, hook
+}
+
+// subscription is a March subscription to plan.
+func subscription(user uuid.UUID, plan domain.PlanCode) *domain.PlanSubscription {
+	return &domain.PlanSubscription{UserID: user, Plan: plan, PeriodStart: march, PeriodEnd: march.AddDate(0, 1, 0), UpdatedAt: march}
+}
+
+func TestEntitlements_DefaultsToConsumer(t *testing.T) {
+	now := march
+	s, repo, _, _ := newService(&now)
+	user := uuid.New()
+	repo.On("GetSubscription", ctx, user).Return(nil, nil).Once()
+
+	ent, err := s.Entitlements(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PlanConsumer, ent.Plan)
+	assert.True(t, ent.FeeRate.Equal(decimal.NewFromFloat(0.015)), "the consumer plan keeps the standard fee")
+	assert.False(t, ent.APIAccess)
+	repo.AssertExpectations(t)
+}
+
+func TestChangePlan_UpgradeIsProrated(t *testing.T) {
+	now := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC) // half of March left
+	s, repo, _, hook := newService(&now)
+	user := uuid.New()
+	repo.On("GetSubscription", ctx, user).Return(subscription(user, domain.PlanConsumer), nil).Once()
+	repo.On("SaveSubscription", ctx, mock.MatchedBy(func(sub *domain.PlanSubscription) bool {
+		return sub.Plan == domain.PlanPremium && sub.PendingPlan == nil && sub.PeriodStart.Equal(march) && sub.UpdatedAt.Equal(now)
+	})).Return(nil).Once()
+	var change *domain.PlanChange
+	hook.On("PlanChanged", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		change = args.Get(1).(*domain.PlanChange)
+	}).Return(nil).Once()
+
+	sub, err := s.ChangePlan(ctx, user, domain.PlanPremium)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PlanPremium, sub.Plan, "upgrades apply at once")
+	require.NotNil(t, change)
+	assert.Equal(t, now, change.EffectiveAt)
+	assert.Equal(t, "2500", change.Proration.String(), "half the period left at a 5000 difference")
+	assert.Equal(t, user, change.ChangedBy)
+	repo.AssertExpectations(t)
+	hook.AssertExpectations(t)
+}
+
+func TestChangePlan_DowngradeWaitsForPeriodEnd(t *testing.T) {
+	now := march.Add(24 * time.Hour)
+	s, repo, _, hook := newService(&now)
+	user := uuid.New()
+	repo.On("GetSubscription", ctx, user).Return(subscription(user, domain.PlanPremium), nil).Once()
+	var saved *domain.PlanSubscription
+	repo.On("SaveSubscription", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*domain.PlanSubscription)
+	}).Return(nil).Once()
+	hook.On("PlanChanged", ctx, mock.MatchedBy(func(c *domain.PlanChange) bool {
+		return c.To == domain.PlanConsumer && c.Proration.IsZero() && c.EffectiveAt.Equal(march.AddDate(0, 1, 0))
+	})).Return(nil).Once()
+
+	sub, err := s.ChangePlan(ctx, user, domain.PlanConsumer)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PlanPremium, sub.Plan)
+	require.NotNil(t, sub.PendingPlan)
+	assert.Equal(t, domain.PlanConsumer, *sub.PendingPlan)
+	repo.AssertExpectations(t)
+	hook.AssertExpectations(t)
+
+	now = sub.PeriodEnd
+	repo.On("GetSubscription", ctx, user).Return(saved, nil).Once()
+	ent, err := s.Entitlements(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PlanConsumer, ent.Plan, "the downgrade applies once the period ends")
+}
+
+func TestChangePlan_SamePlanCancelsPendingDowngrade(t *testing.T) {
+	now := march.Add(24 * time.Hour)
+	s, repo, _, hook := newService(&now)
+	user := uuid.New()
+	pending := subscription(user, domain.PlanPremium)
+	consumer := domain.PlanConsumer
+	pending.PendingPlan = &consumer
+	repo.On("GetSubscription", ctx, user).Return(pending, nil).Once()
+	repo.On("SaveSubscription", ctx, mock.MatchedBy(func(sub *domain.PlanSubscription) bool {
+		return sub.Plan == domain.PlanPremium && sub.PendingPlan == nil
+	})).Return(nil).Once()
+
+	sub, err := s.ChangePlan(ctx, user, domain.PlanPremium)
+	require.NoError(t, err)
+	assert.Nil(t, sub.PendingPlan)
+	repo.AssertExpectations(t)
+	hook.AssertNotCalled(t, "PlanChanged", mock.Anything, mock.Anything)
+}
+
+func TestChangePlan_FailedSaveIsNotAnnounced(t *testing.T) {
+	now := march
+	s, repo, _, hook := newService(&now)
+	user := uuid.New()
+	repo.On("GetSubscription", ctx, user).Return(nil, nil).Once()
+	repo.On("SaveSubscription", ctx, mock.Anything).Return(errors.New("db down")).Once()
+
+	_, err := s.ChangePlan(ctx, user, domain.PlanPremium)
+	assert.Error(t, err)
+	hook.AssertNotCalled(t, "PlanChanged", mock.Anything, mock.Anything)
+}
+
+func TestChangePlan_MerchantOnly(t *testing.T) {
+	now := march
+	s, repo, users, hook := newService(&now)
+	individual, merchant := uuid.New(), uuid.New()
+	users.On("FindByID", ctx, individual).Return(&domain.User{ID: individual, UserType: domain.UserTypeIndividual}, nil)
+	users.On("FindByID", ctx, merchant).Return(&domain.User{ID: merchant, UserType: domain.UserTypeMerchant}, nil)
+	users.On("FindByID", ctx, mock.Anything).Return(nil, pkgerrors.ErrUserNotFound)
+	repo.On("GetSubscription", ctx, mock.Anything).Return(nil, nil)
+	repo.On("SaveSubscription", ctx, mock.MatchedBy(func(sub *domain.PlanSubscription) bool {
+		return sub.Plan == domain.PlanBusiness
+	})).Return(nil)
+	hook.On("PlanChanged", ctx, mock.Anything).Return(errors.New("billing down"))
+
+	_, err := s.ChangePlan(ctx, individual, domain.PlanBusiness)
+	assert.ErrorIs(t, err, ErrPlanNotAvailable)
+	repo.AssertNotCalled(t, "SaveSubscription", mock.Anything, mock.Anything)
+	_, err = s.ChangePlan(ctx, uuid.New(), domain.PlanBusiness)
+	assert.ErrorIs(t, err, pkgerrors.ErrUserNotFound)
+	_, err = s.ChangePlan(ctx, merchant, domain.PlanBusiness)
+	assert.NoError(t, err, "a failing hook leaves the change standing")
+	_, err = s.ChangePlan(ctx, merchant, "platinum")
+	assert.ErrorIs(t, err, ErrUnknownPlan)
+
+	admin := uuid.New()
+	_, err = s.AssignPlan(ctx, individual, domain.PlanBusiness, admin)
+	assert.NoError(t, err, "admins can assign any plan")
+	hook.AssertCalled(t, "PlanChanged", ctx, mock.MatchedBy(func(c *domain.PlanChange) bool {
+		return c.UserID == individual && c.ChangedBy == admin
+	}))
+	users.AssertNumberOfCalls(t, "FindByID", 3)
+}
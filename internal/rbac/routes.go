@@ -0,0 +1,120 @@
+package rbac
+
+import (
+	"net/http"
+	"strings"
+
+	"kyd/internal/domain"
+)
+
+// adminPrefix is where the admin API is mounted.
+const adminPrefix = "/api/v1/admin/"
+
+// routeRule grants access to admin endpoints under pattern: Read for GET
+// and HEAD, Write for everything else. Pattern segments of "*" match any
+// single segment, and a pattern covers every path below it. An empty
+// permission lets any staff member in.
+type routeRule struct {
+	pattern string
+	read    domain.Permission
+	write   domain.Permission
+}
+
+// adminRoutes groups the admin API by permission. The most specific
+// pattern matching a path applies.
+var adminRoutes = []routeRule{
+	{"dashboard", domain.PermissionAnalyticsRead, domain.PermissionAnalyticsRead},
+	{"analytics", domain.PermissionAnalyticsRead, domain.PermissionSystemWrite},
+
+	{"users", domain.PermissionUsersRead, domain.PermissionUsersWrite},
+	{"users/*/roles", domain.PermissionRolesManage, domain.PermissionRolesManage},
+	// Unmasking needs its own permission, which support does not hold;
+	// each use is audited with its reason.
+	{"unmask", domain.PermissionUsersUnmask, domain.PermissionUsersUnmask},
+	{"roles", domain.PermissionRolesManage, domain.PermissionRolesManage},
+	{"permissions", "", ""},
+	{"notifications", "", ""},
+
+	{"compliance", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"kyc", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"cases", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"risk", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"dormancy", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"data-partners", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"complaints", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"jurisdictions", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"security/events", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"security/blocklist", domain.PermissionComplianceRead, domain.PermissionComplianceWrite},
+	{"audit", domain.PermissionComplianceRead, domain.PermissionComplianceRead},
+	{"audit-logs", domain.PermissionComplianceRead, domain.PermissionComplianceRead},
+
+	{"transactions", domain.PermissionTransactionsRead, domain.PermissionTransactionsWrite},
+	{"withdrawals", domain.PermissionTransactionsRead, domain.PermissionTransactionsWrite},
+	{"disputes", domain.PermissionTransactionsRead, domain.PermissionTransactionsWrite},
+	{"wallets", domain.PermissionTransactionsRead, domain.PermissionSystemWrite},
+	{"standing-instructions", domain.PermissionTransactionsRead, domain.PermissionTransactionsWrite},
+
+	{"billing", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"ledger", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"reconciliation", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"banking", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"blockchain", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"credit-facilities", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"fee-schedules", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"fx-spreads", domain.PermissionTreasuryRead, domain.PermissionTreasuryWrite},
+	{"fx-revenue", domain.PermissionTreasuryRead, domain.PermissionTreasuryRead},
+
+	{"broadcasts", domain.PermissionMessagingRead, domain.PermissionMessagingWrite},
+	{"segments", domain.PermissionMessagingRead, domain.PermissionMessagingWrite},
+	{"notifications/experiments", domain.PermissionMessagingRead, domain.PermissionMessagingWrite},
+
+	{"system", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+	{"security/health", domain.PermissionSystemRead, domain.PermissionSystemRead},
+	{"feature-flags", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+	{"webhooks", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+	{"api-keys", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+	{"exports", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+	{"bulk-files", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+	{"report-schedules", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+	{"statements", domain.PermissionSystemRead, domain.PermissionSystemWrite},
+}
+
+// AdminPermission returns the permission a request to the admin API needs.
+// ok is false for paths no rule covers, which only admins may use.
+func AdminPermission(method, path string) (perm domain.Permission, ok bool) {
+	rest := strings.TrimPrefix(path, adminPrefix)
+	if rest == path {
+		return "", false
+	}
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+
+	var best *routeRule
+	bestLen := 0
+	for i := range adminRoutes {
+		rule := &adminRoutes[i]
+		pattern := strings.Split(rule.pattern, "/")
+		if len(pattern) <= bestLen || !matches(pattern, segments) {
+			continue
+		}
+		best, bestLen = rule, len(pattern)
+	}
+	if best == nil {
+		return "", false
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return best.read, true
+	}
+	return best.write, true
+}
+
+func matches(pattern, segments []string) bool {
+	if len(pattern) > len(segments) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
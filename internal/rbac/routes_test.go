@@ -0,0 +1,37 @@
+package rbac
+
+import (
+	"net/http"
+	"testing"
+
+	"kyd/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminPermission(t *testing.T) {
+	cases := []struct {
+		method, path string
+		perm         domain.Permission
+		ok           bool
+	}{
+		{http.MethodGet, "/api/v1/admin/users", domain.PermissionUsersRead, true},
+		{http.MethodPatch, "/api/v1/admin/users/42", domain.PermissionUsersWrite, true},
+		{http.MethodPut, "/api/v1/admin/users/42/roles/support", domain.PermissionRolesManage, true},
+		{http.MethodGet, "/api/v1/admin/users/42/roles", domain.PermissionRolesManage, true},
+		{http.MethodPost, "/api/v1/admin/unmask", domain.PermissionUsersUnmask, true},
+		{http.MethodGet, "/api/v1/admin/security/events", domain.PermissionComplianceRead, true},
+		{http.MethodGet, "/api/v1/admin/security/health", domain.PermissionSystemRead, true},
+		{http.MethodPost, "/api/v1/admin/banking/settlements/1/retry", domain.PermissionTreasuryWrite, true},
+		{http.MethodGet, "/api/v1/admin/disputes", domain.PermissionTransactionsRead, true},
+		{http.MethodPost, "/api/v1/admin/disputes/resolve", domain.PermissionTransactionsWrite, true},
+		{http.MethodGet, "/api/v1/admin/permissions", "", true},
+		{http.MethodGet, "/api/v1/admin/something-new", "", false},
+		{http.MethodGet, "/api/v1/payments", "", false},
+	}
+	for _, c := range cases {
+		perm, ok := AdminPermission(c.method, c.path)
+		assert.Equal(t, c.ok, ok, c.path)
+		assert.Equal(t, c.perm, perm, c.method+" "+c.path)
+	}
+}
@@ -0,0 +1,203 @@
+// Package rbac decides what staff may do. Staff are admin accounts holding
+// one or more roles; each role grants permissions on groups of admin
+// endpoints and a staff member may do what any of their roles allows.
+//
+// Roles and their permissions live in the database. Admins with the
+// roles:manage permission grant and revoke roles through the admin API,
+// which replaces promoting accounts with raw SQL.
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownRole = errors.New("unknown role")
+	ErrNotAssigned = errors.New("user does not hold this role")
+	ErrUnknownUser = errors.New("user not found")
+	ErrLastAdmin   = errors.New("the last admin cannot lose the admin role")
+)
+
+type Repository interface {
+	ListRoles(ctx context.Context) ([]domain.RoleDefinition, error)
+	UserPermissions(ctx context.Context, userID uuid.UUID) ([]domain.Permission, error)
+	UserRoles(ctx context.Context, userID uuid.UUID) ([]domain.RoleAssignment, error)
+	// AssignRole records the assignment and makes the user an admin
+	// account, atomically. Assigning a role the user holds is a no-op.
+	AssignRole(ctx context.Context, a *domain.RoleAssignment) error
+	// RevokeRole returns false when the user did not hold the role, and
+	// domain.ErrLastAdmin instead of removing the admin role from its only
+	// holder.
+	RevokeRole(ctx context.Context, userID uuid.UUID, role domain.Role) (bool, error)
+}
+
+type UserRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// AuditRepository records role changes.
+type AuditRepository interface {
+	Create(ctx context.Context, log *domain.AuditLog) error
+}
+
+type cachedPermissions struct {
+	set       domain.PermissionSet
+	fetchedAt time.Time
+}
+
+type Service struct {
+	repo   Repository
+	users  UserRepository
+	audit  AuditRepository
+	cfg    config.RBACConfig
+	logger logger.Logger
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]cachedPermissions
+}
+
+func NewService(repo Repository, users UserRepository, cfg config.RBACConfig, log logger.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		users:  users,
+		cfg:    cfg,
+		logger: log,
+		now:    time.Now,
+		cache:  make(map[uuid.UUID]cachedPermissions),
+	}
+}
+
+// WithAudit records every grant and revocation in the audit log.
+func (s *Service) WithAudit(audit AuditRepository) *Service {
+	s.audit = audit
+	return s
+}
+
+// Permissions returns what the user's roles allow, as of at most CacheTTL
+// ago on this instance. A user without roles gets an empty set.
+func (s *Service) Permissions(ctx context.Context, userID uuid.UUID) (domain.PermissionSet, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[userID]
+	s.mu.Unlock()
+	if ok && s.now().Sub(cached.fetchedAt) < s.cfg.CacheTTL {
+		return cached.set, nil
+	}
+
+	perms, err := s.repo.UserPermissions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	set := domain.NewPermissionSet(perms...)
+	s.mu.Lock()
+	s.cache[userID] = cachedPermissions{set: set, fetchedAt: s.now()}
+	s.mu.Unlock()
+	return set, nil
+}
+
+// Roles lists the roles with the permissions each grants.
+func (s *Service) Roles(ctx context.Context) ([]domain.RoleDefinition, error) {
+	return s.repo.ListRoles(ctx)
+}
+
+// UserRoles lists the roles the user holds.
+func (s *Service) UserRoles(ctx context.Context, userID uuid.UUID) ([]domain.RoleAssignment, error) {
+	return s.repo.UserRoles(ctx, userID)
+}
+
+// Assign grants role to the user, making their account an admin account if
+// it was not. The user picks up the new permissions at once on this
+// instance, and within CacheTTL elsewhere; an account that was not an admin
+// needs to sign in again.
+func (s *Service) Assign(ctx context.Context, userID uuid.UUID, role domain.Role, grantedBy uuid.UUID) error {
+	if err := s.knownRole(ctx, role); err != nil {
+		return err
+	}
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrUnknownUser
+	}
+	a := &domain.RoleAssignment{UserID: userID, Role: role, GrantedBy: &grantedBy, GrantedAt: s.now().UTC()}
+	if err := s.repo.AssignRole(ctx, a); err != nil {
+		return err
+	}
+	s.forget(userID)
+	s.record(ctx, "ROLE_GRANTED", userID, role, grantedBy)
+	return nil
+}
+
+// Revoke takes role away from the user. The admin role cannot be taken
+// from its last holder, so someone can always manage roles.
+func (s *Service) Revoke(ctx context.Context, userID uuid.UUID, role domain.Role, revokedBy uuid.UUID) error {
+	if err := s.knownRole(ctx, role); err != nil {
+		return err
+	}
+	removed, err := s.repo.RevokeRole(ctx, userID, role)
+	if errors.Is(err, domain.ErrLastAdmin) {
+		return ErrLastAdmin
+	}
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotAssigned
+	}
+	s.forget(userID)
+	s.record(ctx, "ROLE_REVOKED", userID, role, revokedBy)
+	return nil
+}
+
+func (s *Service) knownRole(ctx context.Context, role domain.Role) error {
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if r.Name == role {
+			return nil
+		}
+	}
+	return ErrUnknownRole
+}
+
+func (s *Service) forget(userID uuid.UUID) {
+	s.mu.Lock()
+	delete(s.cache, userID)
+	s.mu.Unlock()
+}
+
+func (s *Service) record(ctx context.Context, action string, userID uuid.UUID, role domain.Role, actorID uuid.UUID) {
+	s.logger.Info("Staff role changed", map[string]interface{}{
+		"event":    "rbac_role_changed",
+		"action":   action,
+		"user_id":  userID,
+		"role":     role,
+		"admin_id": actorID,
+	})
+	if s.audit == nil {
+		return
+	}
+	values, _ := json.Marshal(map[string]domain.Role{"role": role})
+	if err := s.audit.Create(ctx, &domain.AuditLog{
+		ID:         uuid.New(),
+		Action:     action,
+		Resource:   "user_roles",
+		ResourceID: userID.String(),
+		UserID:     &actorID,
+		NewValues:  values,
+		Status:     "success",
+		CreatedAt:  s.now().UTC(),
+	}); err != nil {
+		s.logger.Error("Failed to audit role change", map[string]interface{}{"user_id": userID, "error": err.Error()})
+	}
+}
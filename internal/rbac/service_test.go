@@ -0,0 +1,167 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRepository struct {
+	roles        map[domain.Role][]domain.Permission
+	holders      map[uuid.UUID]map[domain.Role]bool
+	permsQueries int
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		roles: map[domain.Role][]domain.Permission{
+			domain.RoleAdmin:   {domain.PermissionAll},
+			domain.RoleSupport: {domain.PermissionUsersRead, domain.PermissionTransactionsRead},
+		},
+		holders: make(map[uuid.UUID]map[domain.Role]bool),
+	}
+}
+
+func (m *memoryRepository) ListRoles(ctx context.Context) ([]domain.RoleDefinition, error) {
+	var out []domain.RoleDefinition
+	for name, perms := range m.roles {
+		out = append(out, domain.RoleDefinition{Name: name, Permissions: perms})
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) UserPermissions(ctx context.Context, userID uuid.UUID) ([]domain.Permission, error) {
+	m.permsQueries++
+	var out []domain.Permission
+	for role := range m.holders[userID] {
+		out = append(out, m.roles[role]...)
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) UserRoles(ctx context.Context, userID uuid.UUID) ([]domain.RoleAssignment, error) {
+	var out []domain.RoleAssignment
+	for role := range m.holders[userID] {
+		out = append(out, domain.RoleAssignment{UserID: userID, Role: role})
+	}
+	return out, nil
+}
+
+func (m *memoryRepository) AssignRole(ctx context.Context, a *domain.RoleAssignment) error {
+	if m.holders[a.UserID] == nil {
+		m.holders[a.UserID] = make(map[domain.Role]bool)
+	}
+	m.holders[a.UserID][a.Role] = true
+	return nil
+}
+
+func (m *memoryRepository) RevokeRole(ctx context.Context, userID uuid.UUID, role domain.Role) (bool, error) {
+	if !m.holders[userID][role] {
+		return false, nil
+	}
+	if role == domain.RoleAdmin {
+		admins := 0
+		for _, roles := range m.holders {
+			if roles[domain.RoleAdmin] {
+				admins++
+			}
+		}
+		if admins == 1 {
+			return false, domain.ErrLastAdmin
+		}
+	}
+	delete(m.holders[userID], role)
+	return true, nil
+}
+
+type knownUsers map[uuid.UUID]bool
+
+func (k knownUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	if !k[id] {
+		return nil, nil
+	}
+	return &domain.User{ID: id}, nil
+}
+
+type recordedAudit struct{ logs []*domain.AuditLog }
+
+func (r *recordedAudit) Create(ctx context.Context, log *domain.AuditLog) error {
+	r.logs = append(r.logs, log)
+	return nil
+}
+
+func TestAssignAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	admin, agent := uuid.New(), uuid.New()
+	audit := &recordedAudit{}
+	s := NewService(repo, knownUsers{admin: true, agent: true}, config.RBACConfig{CacheTTL: time.Minute}, logger.NewNop()).WithAudit(audit)
+	require.NoError(t, repo.AssignRole(ctx, &domain.RoleAssignment{UserID: admin, Role: domain.RoleAdmin}))
+
+	assert.ErrorIs(t, s.Assign(ctx, agent, "auditor", admin), ErrUnknownRole)
+	assert.ErrorIs(t, s.Assign(ctx, uuid.New(), domain.RoleSupport, admin), ErrUnknownUser)
+
+	require.NoError(t, s.Assign(ctx, agent, domain.RoleSupport, admin))
+	perms, err := s.Permissions(ctx, agent)
+	require.NoError(t, err)
+	assert.True(t, perms.Has(domain.PermissionUsersRead))
+	assert.False(t, perms.Has(domain.PermissionUsersWrite))
+
+	require.NoError(t, s.Revoke(ctx, agent, domain.RoleSupport, admin))
+	perms, err = s.Permissions(ctx, agent)
+	require.NoError(t, err)
+	assert.False(t, perms.Has(domain.PermissionUsersRead), "a revocation applies at once on this instance")
+	assert.ErrorIs(t, s.Revoke(ctx, agent, domain.RoleSupport, admin), ErrNotAssigned)
+
+	require.Len(t, audit.logs, 2)
+	assert.Equal(t, "ROLE_GRANTED", audit.logs[0].Action)
+	assert.JSONEq(t, `{"role":"support"}`, string(audit.logs[0].NewValues))
+	assert.Equal(t, "ROLE_REVOKED", audit.logs[1].Action)
+}
+
+func TestLastAdminKeepsRole(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	first, second := uuid.New(), uuid.New()
+	s := NewService(repo, knownUsers{first: true, second: true}, config.RBACConfig{}, logger.NewNop())
+	require.NoError(t, s.Assign(ctx, first, domain.RoleAdmin, first))
+
+	assert.ErrorIs(t, s.Revoke(ctx, first, domain.RoleAdmin, first), ErrLastAdmin)
+
+	require.NoError(t, s.Assign(ctx, second, domain.RoleAdmin, first))
+	require.NoError(t, s.Revoke(ctx, first, domain.RoleAdmin, second))
+	assert.ErrorIs(t, s.Revoke(ctx, second, domain.RoleAdmin, second), ErrLastAdmin)
+}
+
+func TestPermissionsAreCached(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	user := uuid.New()
+	require.NoError(t, repo.AssignRole(ctx, &domain.RoleAssignment{UserID: user, Role: domain.RoleSupport}))
+	s := NewService(repo, knownUsers{user: true}, config.RBACConfig{CacheTTL: time.Minute}, logger.NewNop())
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := s.Permissions(ctx, user)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, repo.permsQueries)
+
+	// Another instance revoked the role; this one notices once the entry expires
+	delete(repo.holders[user], domain.RoleSupport)
+	perms, _ := s.Permissions(ctx, user)
+	assert.True(t, perms.Has(domain.PermissionUsersRead))
+	now = now.Add(time.Minute)
+	perms, _ = s.Permissions(ctx, user)
+	assert.False(t, perms.Has(domain.PermissionUsersRead))
+	assert.NotNil(t, perms, "staff without roles still get a set")
+}
@@ -0,0 +1,233 @@
+// Package reconciliation checks on a schedule that the money adds up:
+// every wallet's balance against its ledger entries and against its
+// available and reserved split, the customer_wallets account against the
+// wallets themselves, and the general ledger's debits against its credits.
+//
+// Each discrepancy becomes an open break that later runs refresh while it
+// persists and resolve once it is gone. New breaks are sent to admins as
+// ops alerts.
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// ErrRunInProgress is returned when a run is requested while another is
+// still going.
+var ErrRunInProgress = errors.New("a reconciliation run is already in progress")
+
+// alertBreakLimit bounds how many breaks one alert lists.
+const alertBreakLimit = 10
+
+// Repository runs the checks' queries and keeps their breaks.
+type Repository interface {
+	WalletLedgerBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error)
+	WalletSplitBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error)
+	GeneralLedgerBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error)
+	TrialBalanceBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error)
+
+	RecordBreaks(ctx context.Context, check domain.ReconciliationCheck, found []domain.ReconciliationBreak, seenAt time.Time) ([]domain.ReconciliationBreak, int, error)
+	CountOpenBreaks(ctx context.Context) (int, error)
+	ListBreaks(ctx context.Context, check domain.ReconciliationCheck, status domain.ReconciliationBreakStatus, limit, offset int) ([]domain.ReconciliationBreak, int, error)
+}
+
+// AdminDirectory lists the admins alerts go to.
+type AdminDirectory interface {
+	ListAdminIDs(ctx context.Context) ([]uuid.UUID, error)
+}
+
+// Notifier delivers alerts. Satisfied by notification.Service.
+type Notifier interface {
+	SendRaw(ctx context.Context, n *notification.Notification) error
+}
+
+type Service struct {
+	repo     Repository
+	admins   AdminDirectory
+	notifier Notifier
+	cfg      config.ReconciliationConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	running  sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, cfg config.ReconciliationConfig, log logger.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		cfg:    cfg,
+		logger: log,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+}
+
+// WithAlerts sends each run's new breaks to every admin. Without it breaks
+// are only logged and listed.
+func (s *Service) WithAlerts(admins AdminDirectory, notifier Notifier) *Service {
+	s.admins = admins
+	s.notifier = notifier
+	return s
+}
+
+// Start reconciles every configured interval until Stop is called.
+func (s *Service) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Interval)
+				if _, err := s.Run(ctx); err != nil && !errors.Is(err, ErrRunInProgress) {
+					s.logger.Error("Reconciliation run failed", map[string]interface{}{"error": err.Error()})
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Run runs every check once, records its breaks and alerts admins to the
+// new ones. A check that fails is listed in the run's Failed and leaves
+// its breaks untouched, so an outage does not resolve them.
+func (s *Service) Run(ctx context.Context) (*domain.ReconciliationRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer s.running.Unlock()
+
+	run := &domain.ReconciliationRun{StartedAt: s.now(), Opened: []domain.ReconciliationBreak{}, Failed: []string{}}
+	for _, check := range domain.ReconciliationChecks {
+		seenAt := s.now()
+		found, err := s.find(ctx, check)
+		if err == nil {
+			var opened []domain.ReconciliationBreak
+			var resolved int
+			opened, resolved, err = s.repo.RecordBreaks(ctx, check, found, seenAt)
+			run.Opened = append(run.Opened, opened...)
+			run.Resolved += resolved
+		}
+		if err != nil {
+			s.logger.Error("Reconciliation check failed", map[string]interface{}{"check": check, "error": err.Error()})
+			run.Failed = append(run.Failed, string(check))
+		}
+	}
+
+	open, err := s.repo.CountOpenBreaks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	run.Open = open
+	run.FinishedAt = s.now()
+
+	fields := map[string]interface{}{
+		"open":     run.Open,
+		"opened":   len(run.Opened),
+		"resolved": run.Resolved,
+		"failed":   run.Failed,
+	}
+	if len(run.Opened) > 0 || len(run.Failed) > 0 {
+		s.logger.Warn("Reconciliation found new breaks", fields)
+	} else {
+		s.logger.Info("Reconciliation completed", fields)
+	}
+	if len(run.Opened) > 0 {
+		s.alert(ctx, run.Opened)
+	}
+	return run, nil
+}
+
+func (s *Service) find(ctx context.Context, check domain.ReconciliationCheck) ([]domain.ReconciliationBreak, error) {
+	switch check {
+	case domain.ReconcileWalletLedger:
+		return s.repo.WalletLedgerBreaks(ctx)
+	case domain.ReconcileWalletSplit:
+		return s.repo.WalletSplitBreaks(ctx)
+	case domain.ReconcileGeneralLedger:
+		return s.repo.GeneralLedgerBreaks(ctx)
+	case domain.ReconcileTrialBalance:
+		return s.repo.TrialBalanceBreaks(ctx)
+	}
+	return nil, nil
+}
+
+// alert sends one ops alert listing the new breaks to every admin.
+func (s *Service) alert(ctx context.Context, opened []domain.ReconciliationBreak) {
+	if s.notifier == nil || s.admins == nil {
+		return
+	}
+	admins, err := s.admins.ListAdminIDs(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list alert recipients", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	subject, body := describeBreaks(opened)
+	for _, id := range admins {
+		err := s.notifier.SendRaw(ctx, &notification.Notification{
+			ID:        uuid.New(),
+			UserID:    id,
+			Type:      "OPS_ALERT",
+			Channel:   notification.ChannelEmail,
+			Priority:  notification.PriorityUrgent,
+			Subject:   subject,
+			Body:      body,
+			Metadata:  map[string]interface{}{"breaks": len(opened)},
+			CreatedAt: s.now(),
+		})
+		if err != nil {
+			s.logger.Error("Failed to send ops alert", map[string]interface{}{"error": err.Error(), "user_id": id})
+		}
+	}
+}
+
+func describeBreaks(opened []domain.ReconciliationBreak) (string, string) {
+	subject := fmt.Sprintf("Ops alert: %d new reconciliation break", len(opened))
+	if len(opened) != 1 {
+		subject += "s"
+	}
+	var b strings.Builder
+	for i, br := range opened {
+		if i == alertBreakLimit {
+			fmt.Fprintf(&b, "...and %d more; see /admin/reconciliation/breaks\n", len(opened)-alertBreakLimit)
+			break
+		}
+		fmt.Fprintf(&b, "%s: %s (off by %s %s)\n", br.Check, br.Detail, br.Difference().StringFixed(2), br.Currency)
+	}
+	return subject, b.String()
+}
+
+// ListBreaks returns breaks, most recently seen first. status defaults to
+// open; "all" lists resolved breaks too.
+func (s *Service) ListBreaks(ctx context.Context, check domain.ReconciliationCheck, status domain.ReconciliationBreakStatus, limit, offset int) ([]domain.ReconciliationBreak, int, error) {
+	switch status {
+	case "":
+		status = domain.ReconciliationBreakOpen
+	case "all":
+		status = ""
+	}
+	return s.repo.ListBreaks(ctx, check, status, limit, offset)
+}
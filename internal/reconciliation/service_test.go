@@ -0,0 +1,259 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) breaks(args mock.Arguments) ([]domain.ReconciliationBreak, error) {
+	found, _ := args.Get(0).([]domain.ReconciliationBreak)
+	return found, args.Error(1)
+}
+
+func (m *MockRepository) WalletLedgerBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return m.breaks(m.Called(ctx))
+}
+
+func (m *MockRepository) WalletSplitBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return m.breaks(m.Called(ctx))
+}
+
+func (m *MockRepository) GeneralLedgerBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return m.breaks(m.Called(ctx))
+}
+
+func (m *MockRepository) TrialBalanceBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return m.breaks(m.Called(ctx))
+}
+
+func (m *MockRepository) RecordBreaks(ctx context.Context, check domain.ReconciliationCheck, found []domain.ReconciliationBreak, seenAt time.Time) ([]domain.ReconciliationBreak, int, error) {
+	args := m.Called(ctx, check, found, seenAt)
+	opened, _ := args.Get(0).([]domain.ReconciliationBreak)
+	return opened, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) CountOpenBreaks(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ListBreaks(ctx context.Context, check domain.ReconciliationCheck, status domain.ReconciliationBreakStatus, limit, offset int) ([]domain.ReconciliationBreak, int, error) {
+	args := m.Called(ctx, check, status, limit, offset)
+	breaks, _ := args.Get(0).([]domain.ReconciliationBreak)
+	return breaks, args.Int(1), args.Error(2)
+}
+
+type MockAdmins struct {
+	mock.Mock
+}
+
+func (m *MockAdmins) ListAdminIDs(ctx context.Context) ([]uuid.UUID, error) {
+	args := m.Called(ctx)
+	ids, _ := args.Get(0).([]uuid.UUID)
+	return ids, args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) SendRaw(ctx context.Context, n *notification.Notification) error {
+	return m.Called(ctx, n).Error(0)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 4, 1, 3, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *MockRepository) {
+	repo := new(MockRepository)
+	s := NewService(repo, config.ReconciliationConfig{Enabled: true, Interval: time.Hour}, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo
+}
+
+// balanced expects every check to find nothing, unless the test has
+// already said otherwise, and its breaks to be recorded.
+func balanced(repo *MockRepository, now time.Time) {
+	repo.On("WalletLedgerBreaks", ctx).Return(nil, nil).Maybe()
+	repo.On("WalletSplitBreaks", ctx).Return(nil, nil).Maybe()
+	repo.On("GeneralLedgerBreaks", ctx).Return(nil, nil).Maybe()
+	repo.On("TrialBalanceBreaks", ctx).Return(nil, nil).Maybe()
+	repo.On("RecordBreaks", ctx, mock.Anything, mock.Anything, now).Return(nil, 0, nil).Maybe()
+}
+
+func walletBreak(check domain.ReconciliationCheck, subject, expected, actual string) domain.ReconciliationBreak {
+	return domain.ReconciliationBreak{
+		Check:    check,
+		Subject:  subject,
+		Currency: "MWK",
+		Expected: decimal.RequireFromString(expected),
+		Actual:   decimal.RequireFromString(actual),
+		Detail:   "wallet " + subject + " is off",
+	}
+}
+
+func TestRun_RecordsEachCheck(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	ledger := []domain.ReconciliationBreak{walletBreak(domain.ReconcileWalletLedger, "w1", "100", "90"), walletBreak(domain.ReconcileWalletLedger, "w2", "5", "0")}
+	gl := []domain.ReconciliationBreak{walletBreak(domain.ReconcileGeneralLedger, "MWK", "1000", "990")}
+	repo.On("WalletLedgerBreaks", ctx).Return(ledger, nil).Once()
+	repo.On("GeneralLedgerBreaks", ctx).Return(gl, nil).Once()
+	repo.On("RecordBreaks", ctx, domain.ReconcileWalletLedger, ledger, now).Return(ledger[1:], 0, nil).Once()
+	repo.On("RecordBreaks", ctx, domain.ReconcileWalletSplit, ([]domain.ReconciliationBreak)(nil), now).Return(nil, 2, nil).Once()
+	repo.On("RecordBreaks", ctx, domain.ReconcileGeneralLedger, gl, now).Return(gl, 0, nil).Once()
+	balanced(repo, now)
+	repo.On("CountOpenBreaks", ctx).Return(3, nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.ReconciliationBreak{ledger[1], gl[0]}, run.Opened, "only new breaks")
+	assert.Equal(t, 2, run.Resolved)
+	assert.Equal(t, 3, run.Open)
+	assert.Empty(t, run.Failed)
+	assert.True(t, run.FinishedAt.Equal(now))
+	repo.AssertExpectations(t)
+}
+
+func TestRun_FailedCheckKeepsItsBreaks(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	repo.On("GeneralLedgerBreaks", ctx).Return(nil, errors.New("connection reset")).Once()
+	repo.On("RecordBreaks", ctx, domain.ReconcileTrialBalance, mock.Anything, now).Return(nil, 0, errors.New("deadlock")).Once()
+	balanced(repo, now)
+	repo.On("CountOpenBreaks", ctx).Return(2, nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{string(domain.ReconcileGeneralLedger), string(domain.ReconcileTrialBalance)}, run.Failed)
+	repo.AssertNotCalled(t, "RecordBreaks", ctx, domain.ReconcileGeneralLedger, mock.Anything, mock.Anything)
+	repo.AssertExpectations(t)
+
+	t.Run("the run cannot be counted", func(t *testing.T) {
+		s, repo := newService(&now)
+		balanced(repo, now)
+		repo.On("CountOpenBreaks", ctx).Return(0, errors.New("connection reset")).Once()
+
+		_, err := s.Run(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func TestRun_AlertsAdminsToNewBreaksOnly(t *testing.T) {
+	now := start
+	ops := []uuid.UUID{uuid.New(), uuid.New()}
+	split := []domain.ReconciliationBreak{walletBreak(domain.ReconcileWalletSplit, "w1", "100", "90")}
+
+	t.Run("new breaks", func(t *testing.T) {
+		s, repo := newService(&now)
+		admins, notifier := new(MockAdmins), new(MockNotifier)
+		s.WithAlerts(admins, notifier)
+		repo.On("WalletSplitBreaks", ctx).Return(split, nil).Once()
+		repo.On("RecordBreaks", ctx, domain.ReconcileWalletSplit, split, now).Return(split, 0, nil).Once()
+		balanced(repo, now)
+		repo.On("CountOpenBreaks", ctx).Return(1, nil).Once()
+		admins.On("ListAdminIDs", ctx).Return(ops, nil).Once()
+		for _, id := range ops {
+			id := id
+			notifier.On("SendRaw", ctx, mock.MatchedBy(func(n *notification.Notification) bool {
+				return n.UserID == id && n.Type == "OPS_ALERT" && n.Priority == notification.PriorityUrgent &&
+					n.Subject == "Ops alert: 1 new reconciliation break" &&
+					n.Body == "wallet_split: wallet w1 is off (off by -10.00 MWK)\n"
+			})).Return(nil).Once()
+		}
+
+		_, err := s.Run(ctx)
+		require.NoError(t, err)
+		admins.AssertExpectations(t)
+		notifier.AssertExpectations(t)
+	})
+
+	t.Run("breaks seen before", func(t *testing.T) {
+		s, repo := newService(&now)
+		admins, notifier := new(MockAdmins), new(MockNotifier)
+		s.WithAlerts(admins, notifier)
+		repo.On("WalletSplitBreaks", ctx).Return(split, nil).Once()
+		balanced(repo, now)
+		repo.On("CountOpenBreaks", ctx).Return(1, nil).Once()
+
+		_, err := s.Run(ctx)
+		require.NoError(t, err)
+		admins.AssertNotCalled(t, "ListAdminIDs", mock.Anything)
+		notifier.AssertNotCalled(t, "SendRaw", mock.Anything, mock.Anything)
+	})
+
+	t.Run("no admins to alert", func(t *testing.T) {
+		s, repo := newService(&now)
+		admins, notifier := new(MockAdmins), new(MockNotifier)
+		s.WithAlerts(admins, notifier)
+		repo.On("RecordBreaks", ctx, domain.ReconcileWalletLedger, mock.Anything, now).Return(split, 0, nil).Once()
+		balanced(repo, now)
+		repo.On("CountOpenBreaks", ctx).Return(1, nil).Once()
+		admins.On("ListAdminIDs", ctx).Return(nil, errors.New("connection reset")).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err, "the run is recorded all the same")
+		assert.Len(t, run.Opened, 1)
+		notifier.AssertNotCalled(t, "SendRaw", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDescribeBreaks_Truncates(t *testing.T) {
+	var opened []domain.ReconciliationBreak
+	for i := 0; i < alertBreakLimit+3; i++ {
+		opened = append(opened, walletBreak(domain.ReconcileWalletLedger, uuid.NewString(), "1", "2"))
+	}
+	subject, body := describeBreaks(opened)
+	assert.Equal(t, "Ops alert: 13 new reconciliation breaks", subject)
+	assert.Contains(t, body, "...and 3 more")
+}
+
+func TestRun_RejectsConcurrentRuns(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	s.running.Lock()
+	_, err := s.Run(ctx)
+	assert.ErrorIs(t, err, ErrRunInProgress)
+	s.running.Unlock()
+	repo.AssertNotCalled(t, "CountOpenBreaks", mock.Anything)
+}
+
+func TestListBreaks(t *testing.T) {
+	now := start
+	tests := []struct {
+		status domain.ReconciliationBreakStatus
+		want   domain.ReconciliationBreakStatus
+	}{
+		{"", domain.ReconciliationBreakOpen},
+		{"all", ""},
+		{domain.ReconciliationBreakResolved, domain.ReconciliationBreakResolved},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			s, repo := newService(&now)
+			repo.On("ListBreaks", ctx, domain.ReconcileWalletLedger, tt.want, 50, 0).Return(nil, 0, nil).Once()
+			_, _, err := s.ListBreaks(ctx, domain.ReconcileWalletLedger, tt.status, 50, 0)
+			require.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,264 @@
+// Package recovery works out why transactions stopped moving and gets them
+// going again where that is safe: a payment that never reached the ledger
+// is posted again under its idempotency key, a payment left settling in a
+// settlement that does not exist goes back to batching, and a withdrawal
+// that was never sent to its provider has its reservation released.
+//
+// Anything else, typically a partner that never reported an outcome, could
+// have moved money outside the platform, so it goes to an exception queue
+// for an admin instead.
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/funding"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRunInProgress      = errors.New("a recovery run is already in progress")
+	ErrExceptionNotFound  = errors.New("no open exception with that id")
+	ErrResolutionRequired = errors.New("a resolution is required")
+)
+
+// runLimit bounds how many stuck transactions one run examines.
+const runLimit = 200
+
+// stuckStatuses are the in-flight states recovery looks at. Payments held
+// for approval, queued for a window or in escrow wait on purpose.
+var stuckStatuses = []domain.TransactionStatus{
+	domain.TransactionStatusPending,
+	domain.TransactionStatusProcessing,
+	domain.TransactionStatusPendingSettlement,
+	domain.TransactionStatusSettling,
+}
+
+type Repository interface {
+	FindStuck(ctx context.Context, statuses []domain.TransactionStatus, before time.Time, limit int) ([]*domain.StuckTransaction, error)
+	ReturnToSettlement(ctx context.Context, id uuid.UUID) (bool, error)
+	CreateException(ctx context.Context, e *domain.TransactionException) (bool, error)
+	ListExceptions(ctx context.Context, status domain.TransactionExceptionStatus, reason domain.StuckReason, limit, offset int) ([]domain.TransactionException, int, error)
+	ResolveException(ctx context.Context, id, adminID uuid.UUID, resolution string, at time.Time) (*domain.TransactionException, error)
+}
+
+// Payments posts stuck payments again. Satisfied by payment.Service.
+type Payments interface {
+	ResubmitPayment(ctx context.Context, tx *domain.Transaction) error
+}
+
+// Withdrawals releases withdrawals that were never sent. Satisfied by
+// funding.Service.
+type Withdrawals interface {
+	ReleaseWithdrawal(ctx context.Context, tx *domain.Transaction, reason string) (bool, error)
+}
+
+type Service struct {
+	repo        Repository
+	payments    Payments
+	withdrawals Withdrawals
+	cfg         config.RecoveryConfig
+	logger      logger.Logger
+	now         func() time.Time
+
+	running  sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, payments Payments, withdrawals Withdrawals, cfg config.RecoveryConfig, log logger.Logger) *Service {
+	return &Service{
+		repo:        repo,
+		payments:    payments,
+		withdrawals: withdrawals,
+		cfg:         cfg,
+		logger:      log,
+		now:         time.Now,
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start runs recovery every configured interval until Stop is called.
+func (s *Service) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := s.Run(context.Background()); err != nil && !errors.Is(err, ErrRunInProgress) {
+					s.logger.Error("Stuck transaction recovery failed", map[string]interface{}{"error": err.Error()})
+				}
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Run examines transactions that have not moved for the configured time,
+// fixes those it safely can and escalates the rest.
+func (s *Service) Run(ctx context.Context) (*domain.RecoveryRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer s.running.Unlock()
+
+	stuck, err := s.repo.FindStuck(ctx, stuckStatuses, s.now().Add(-s.cfg.StuckAfter), runLimit)
+	if err != nil {
+		return nil, err
+	}
+	run := &domain.RecoveryRun{Examined: len(stuck)}
+	for _, st := range stuck {
+		reason, action, detail := classify(st)
+		if action != domain.RecoveryEscalate {
+			recovered, err := s.remediate(ctx, st, reason, action)
+			if err == nil {
+				if recovered {
+					run.Recovered++
+					s.logger.Info("Stuck transaction recovered", map[string]interface{}{
+						"transaction_id": st.ID, "reason": reason, "action": action,
+					})
+				}
+				continue
+			}
+			s.logger.Error("Stuck transaction remediation failed", map[string]interface{}{
+				"transaction_id": st.ID, "reason": reason, "action": action, "error": err.Error(),
+			})
+			detail = fmt.Sprintf("%s; %s failed: %s", detail, action, err.Error())
+		}
+
+		created, err := s.repo.CreateException(ctx, &domain.TransactionException{
+			ID:            uuid.New(),
+			TransactionID: st.ID,
+			Reference:     st.Reference,
+			Reason:        reason,
+			Detail:        detail,
+			Status:        domain.TransactionExceptionOpen,
+			CreatedAt:     s.now(),
+		})
+		if err != nil {
+			run.Failed++
+			s.logger.Error("Failed to escalate stuck transaction", map[string]interface{}{"transaction_id": st.ID, "error": err.Error()})
+			continue
+		}
+		if created {
+			run.Escalated++
+			s.logger.Warn("Stuck transaction escalated", map[string]interface{}{
+				"transaction_id": st.ID, "reason": reason, "detail": detail,
+			})
+		}
+	}
+	return run, nil
+}
+
+// classify works out why a transaction is stuck and what to do about it.
+func classify(st *domain.StuckTransaction) (domain.StuckReason, domain.RecoveryAction, string) {
+	switch st.TransactionType {
+	case domain.TransactionTypeWithdrawal:
+		switch st.Status {
+		case domain.TransactionStatusPending:
+			return domain.StuckNotDispatched, domain.RecoveryReleaseReservation,
+				"withdrawal was reserved but never sent to the payout provider"
+		case domain.TransactionStatusProcessing:
+			return domain.StuckPartnerTimeout, domain.RecoveryEscalate,
+				fmt.Sprintf("no payout outcome from %v; check with the provider before failing or completing it", st.Metadata[funding.ProviderKey])
+		}
+	case domain.TransactionTypeDeposit:
+		if st.Status == domain.TransactionStatusPending {
+			return domain.StuckPartnerTimeout, domain.RecoveryEscalate,
+				fmt.Sprintf("no top-up outcome from %v; check with the provider whether the money arrived", st.Metadata[funding.ProviderKey])
+		}
+	case domain.TransactionTypePayment:
+		switch st.Status {
+		case domain.TransactionStatusPending, domain.TransactionStatusProcessing:
+			if st.HasLedgerEntries {
+				return domain.StuckMissingLedgerEntry, domain.RecoveryResubmit,
+					"posted to the ledger but never moved on to settlement"
+			}
+			return domain.StuckMissingLedgerEntry, domain.RecoveryResubmit, "never posted to the ledger"
+		case domain.TransactionStatusPendingSettlement:
+			if !st.HasLedgerEntries {
+				return domain.StuckMissingLedgerEntry, domain.RecoveryEscalate,
+					"waiting for settlement but has no ledger entries"
+			}
+			return domain.StuckNoSettlementRecord, domain.RecoveryEscalate,
+				"not picked up by settlement batching; check the corridor's counterparties and routes"
+		case domain.TransactionStatusSettling:
+			if st.SettlementStatus == nil {
+				return domain.StuckNoSettlementRecord, domain.RecoveryResubmit,
+					"settling in a settlement that does not exist"
+			}
+			switch *st.SettlementStatus {
+			case domain.SettlementStatusFailed:
+				return domain.StuckNoSettlementRecord, domain.RecoveryEscalate,
+					"its settlement failed; retry the settlement"
+			case domain.SettlementStatusPending, domain.SettlementStatusProcessing, domain.SettlementStatusSubmitted:
+				return domain.StuckPartnerTimeout, domain.RecoveryEscalate,
+					fmt.Sprintf("its settlement is still %s on the network", *st.SettlementStatus)
+			}
+		}
+	}
+	return domain.StuckUnclassified, domain.RecoveryEscalate,
+		fmt.Sprintf("%s stuck in %s", st.TransactionType, st.Status)
+}
+
+// remediate applies action, reporting false when the transaction moved on
+// by itself in the meantime.
+func (s *Service) remediate(ctx context.Context, st *domain.StuckTransaction, reason domain.StuckReason, action domain.RecoveryAction) (bool, error) {
+	switch {
+	case action == domain.RecoveryReleaseReservation:
+		return s.withdrawals.ReleaseWithdrawal(ctx, &st.Transaction, "Recovery: withdrawal was never sent to the payout provider")
+	case action == domain.RecoveryResubmit && reason == domain.StuckNoSettlementRecord:
+		return s.repo.ReturnToSettlement(ctx, st.ID)
+	case action == domain.RecoveryResubmit:
+		return true, s.payments.ResubmitPayment(ctx, &st.Transaction)
+	}
+	return false, fmt.Errorf("no remediation for %s", action)
+}
+
+// ListExceptions returns the exception queue, newest first. status
+// defaults to open; "all" lists resolved exceptions too.
+func (s *Service) ListExceptions(ctx context.Context, status domain.TransactionExceptionStatus, reason domain.StuckReason, limit, offset int) ([]domain.TransactionException, int, error) {
+	switch status {
+	case "":
+		status = domain.TransactionExceptionOpen
+	case "all":
+		status = ""
+	}
+	return s.repo.ListExceptions(ctx, status, reason, limit, offset)
+}
+
+// ResolveException closes an exception once an admin has dealt with its
+// transaction. Recovery looks at the transaction again on its next run if
+// it is still stuck.
+func (s *Service) ResolveException(ctx context.Context, id, adminID uuid.UUID, resolution string) (*domain.TransactionException, error) {
+	resolution = strings.TrimSpace(resolution)
+	if resolution == "" {
+		return nil, ErrResolutionRequired
+	}
+	e, err := s.repo.ResolveException(ctx, id, adminID, resolution, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrExceptionNotFound
+	}
+	return e, nil
+}
@@ -0,0 +1,234 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/funding"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) FindStuck(ctx context.Context, statuses []domain.TransactionStatus, before time.Time, limit int) ([]*domain.StuckTransaction, error) {
+	args := m.Called(ctx, statuses, before, limit)
+	stuck, _ := args.Get(0).([]*domain.StuckTransaction)
+	return stuck, args.Error(1)
+}
+
+func (m *MockRepository) ReturnToSettlement(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) CreateException(ctx context.Context, e *domain.TransactionException) (bool, error) {
+	args := m.Called(ctx, e)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ListExceptions(ctx context.Context, status domain.TransactionExceptionStatus, reason domain.StuckReason, limit, offset int) ([]domain.TransactionException, int, error) {
+	args := m.Called(ctx, status, reason, limit, offset)
+	exceptions, _ := args.Get(0).([]domain.TransactionException)
+	return exceptions, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) ResolveException(ctx context.Context, id, adminID uuid.UUID, resolution string, at time.Time) (*domain.TransactionException, error) {
+	args := m.Called(ctx, id, adminID, resolution, at)
+	e, _ := args.Get(0).(*domain.TransactionException)
+	return e, args.Error(1)
+}
+
+type MockPayments struct {
+	mock.Mock
+}
+
+func (m *MockPayments) ResubmitPayment(ctx context.Context, tx *domain.Transaction) error {
+	return m.Called(ctx, tx).Error(0)
+}
+
+type MockWithdrawals struct {
+	mock.Mock
+}
+
+func (m *MockWithdrawals) ReleaseWithdrawal(ctx context.Context, tx *domain.Transaction, reason string) (bool, error) {
+	args := m.Called(ctx, tx, reason)
+	return args.Bool(0), args.Error(1)
+}
+
+type mocks struct {
+	repo        *MockRepository
+	payments    *MockPayments
+	withdrawals *MockWithdrawals
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+	m.withdrawals.AssertExpectations(t)
+}
+
+var (
+	ctx = context.Background()
+	now = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+)
+
+func stuck(txType domain.TransactionType, status domain.TransactionStatus, ledger bool, settlement *domain.SettlementStatus) *domain.StuckTransaction {
+	return &domain.StuckTransaction{
+		Transaction: domain.Transaction{
+			ID:              uuid.New(),
+			Reference:       "REF-" + string(status),
+			TransactionType: txType,
+			Status:          status,
+			Metadata:        domain.Metadata{funding.ProviderKey: "paychangu"},
+		},
+		HasLedgerEntries: ledger,
+		SettlementStatus: settlement,
+	}
+}
+
+func settlementStatus(s domain.SettlementStatus) *domain.SettlementStatus { return &s }
+
+func newService() (*Service, *mocks) {
+	m := &mocks{repo: &MockRepository{}, payments: &MockPayments{}, withdrawals: &MockWithdrawals{}}
+	s := NewService(m.repo, m.payments, m.withdrawals, config.RecoveryConfig{
+		Enabled: true, Interval: time.Minute, StuckAfter: 24 * time.Hour,
+	}, logger.NewNop())
+	s.now = func() time.Time { return now }
+	return s, m
+}
+
+// finds expects a run to find stuck.
+func finds(m *mocks, stuck ...*domain.StuckTransaction) {
+	m.repo.On("FindStuck", ctx, stuckStatuses, now.Add(-24*time.Hour), runLimit).Return(stuck, nil).Once()
+}
+
+// escalates expects st to be escalated for reason, with a detail
+// containing detail, and reports created.
+func escalates(m *mocks, st *domain.StuckTransaction, reason domain.StuckReason, detail string, created bool, err error) {
+	m.repo.On("CreateException", ctx, mock.MatchedBy(func(e *domain.TransactionException) bool {
+		return e.TransactionID == st.ID && e.Reference == st.Reference && e.Reason == reason &&
+			strings.Contains(e.Detail, detail) && e.Status == domain.TransactionExceptionOpen && e.CreatedAt.Equal(now)
+	})).Return(created, err).Once()
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		name   string
+		st     *domain.StuckTransaction
+		reason domain.StuckReason
+		action domain.RecoveryAction
+	}{
+		{"payment never posted", stuck(domain.TransactionTypePayment, domain.TransactionStatusPending, false, nil), domain.StuckMissingLedgerEntry, domain.RecoveryResubmit},
+		{"payment posted without moving on", stuck(domain.TransactionTypePayment, domain.TransactionStatusProcessing, true, nil), domain.StuckMissingLedgerEntry, domain.RecoveryResubmit},
+		{"awaiting settlement without ledger", stuck(domain.TransactionTypePayment, domain.TransactionStatusPendingSettlement, false, nil), domain.StuckMissingLedgerEntry, domain.RecoveryEscalate},
+		{"never batched", stuck(domain.TransactionTypePayment, domain.TransactionStatusPendingSettlement, true, nil), domain.StuckNoSettlementRecord, domain.RecoveryEscalate},
+		{"settlement missing", stuck(domain.TransactionTypePayment, domain.TransactionStatusSettling, true, nil), domain.StuckNoSettlementRecord, domain.RecoveryResubmit},
+		{"settlement failed", stuck(domain.TransactionTypePayment, domain.TransactionStatusSettling, true, settlementStatus(domain.SettlementStatusFailed)), domain.StuckNoSettlementRecord, domain.RecoveryEscalate},
+		{"settlement unconfirmed", stuck(domain.TransactionTypePayment, domain.TransactionStatusSettling, true, settlementStatus(domain.SettlementStatusSubmitted)), domain.StuckPartnerTimeout, domain.RecoveryEscalate},
+		{"withdrawal never sent", stuck(domain.TransactionTypeWithdrawal, domain.TransactionStatusPending, false, nil), domain.StuckNotDispatched, domain.RecoveryReleaseReservation},
+		{"payout unanswered", stuck(domain.TransactionTypeWithdrawal, domain.TransactionStatusProcessing, false, nil), domain.StuckPartnerTimeout, domain.RecoveryEscalate},
+		{"top-up unanswered", stuck(domain.TransactionTypeDeposit, domain.TransactionStatusPending, false, nil), domain.StuckPartnerTimeout, domain.RecoveryEscalate},
+		{"other", stuck(domain.TransactionTypeRefund, domain.TransactionStatusPending, false, nil), domain.StuckUnclassified, domain.RecoveryEscalate},
+	}
+	for _, c := range cases {
+		reason, action, detail := classify(c.st)
+		assert.Equal(t, c.reason, reason, c.name)
+		assert.Equal(t, c.action, action, c.name)
+		assert.NotEmpty(t, detail, c.name)
+	}
+}
+
+func TestRun_RemediatesAndEscalates(t *testing.T) {
+	s, m := newService()
+	payment := stuck(domain.TransactionTypePayment, domain.TransactionStatusPending, false, nil)
+	settling := stuck(domain.TransactionTypePayment, domain.TransactionStatusSettling, true, nil)
+	withdrawal := stuck(domain.TransactionTypeWithdrawal, domain.TransactionStatusPending, false, nil)
+	payout := stuck(domain.TransactionTypeWithdrawal, domain.TransactionStatusProcessing, false, nil)
+	finds(m, payment, settling, withdrawal, payout)
+	m.payments.On("ResubmitPayment", ctx, &payment.Transaction).Return(nil).Once()
+	m.repo.On("ReturnToSettlement", ctx, settling.ID).Return(true, nil).Once()
+	m.withdrawals.On("ReleaseWithdrawal", ctx, &withdrawal.Transaction, mock.Anything).Return(true, nil).Once()
+	escalates(m, payout, domain.StuckPartnerTimeout, "paychangu", true, nil)
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RecoveryRun{Examined: 4, Recovered: 3, Escalated: 1}, *run)
+	m.assert(t)
+
+	t.Run("moved on meanwhile or already escalated", func(t *testing.T) {
+		s, m := newService()
+		finds(m, settling, withdrawal, payout)
+		m.repo.On("ReturnToSettlement", ctx, settling.ID).Return(false, nil).Once()
+		m.withdrawals.On("ReleaseWithdrawal", ctx, &withdrawal.Transaction, mock.Anything).Return(false, nil).Once()
+		escalates(m, payout, domain.StuckPartnerTimeout, "paychangu", false, nil)
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RecoveryRun{Examined: 3}, *run)
+		m.assert(t)
+	})
+
+	t.Run("a failed escalation is counted", func(t *testing.T) {
+		s, m := newService()
+		finds(m, payout)
+		escalates(m, payout, domain.StuckPartnerTimeout, "paychangu", false, errors.New("db down"))
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RecoveryRun{Examined: 1, Failed: 1}, *run)
+	})
+}
+
+func TestRun_EscalatesFailedRemediation(t *testing.T) {
+	s, m := newService()
+	payment := stuck(domain.TransactionTypePayment, domain.TransactionStatusPending, false, nil)
+	finds(m, payment)
+	m.payments.On("ResubmitPayment", ctx, &payment.Transaction).Return(errors.New("wallet not found")).Once()
+	escalates(m, payment, domain.StuckMissingLedgerEntry, "resubmit failed: wallet not found", true, nil)
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, run.Recovered)
+	assert.Equal(t, 1, run.Escalated)
+	m.assert(t)
+}
+
+func TestExceptions(t *testing.T) {
+	s, m := newService()
+	id, admin := uuid.New(), uuid.New()
+	resolution := "provider confirmed the payout; completed manually"
+
+	_, err := s.ResolveException(ctx, id, admin, " ")
+	assert.ErrorIs(t, err, ErrResolutionRequired)
+	resolved := &domain.TransactionException{ID: id, Status: domain.TransactionExceptionResolved, Resolution: &resolution}
+	m.repo.On("ResolveException", ctx, id, admin, resolution, now).Return(resolved, nil).Once()
+	e, err := s.ResolveException(ctx, id, admin, " "+resolution)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TransactionExceptionResolved, e.Status)
+	m.repo.On("ResolveException", ctx, id, admin, "again", now).Return(nil, nil).Once()
+	_, err = s.ResolveException(ctx, id, admin, "again")
+	assert.ErrorIs(t, err, ErrExceptionNotFound, "no longer open")
+
+	m.repo.On("ListExceptions", ctx, domain.TransactionExceptionOpen, domain.StuckReason(""), 50, 0).Return(nil, 0, nil).Once()
+	m.repo.On("ListExceptions", ctx, domain.TransactionExceptionStatus(""), domain.StuckPartnerTimeout, 50, 0).Return([]domain.TransactionException{*resolved}, 1, nil).Once()
+	open, _, err := s.ListExceptions(ctx, "", "", 50, 0)
+	require.NoError(t, err)
+	assert.Empty(t, open, "open ones by default")
+	all, total, err := s.ListExceptions(ctx, "all", domain.StuckPartnerTimeout, 50, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, 1, total)
+	m.assert(t)
+}
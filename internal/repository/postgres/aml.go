@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+const amlScreeningColumns = `id, user_id, provider, screened_name, countries, sanction_hit, pep, country_hit,
+	risk_score, matches, cleared_by, cleared_at, clear_reason, screened_at`
+
+// CreateScreening stores a screening and copies its risk score to the
+// user, in one transaction.
+func (r *KYCRepository) CreateScreening(ctx context.Context, s *domain.AMLScreening) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.aml_screenings (`+amlScreeningColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, s.ID, s.UserID, s.Provider, s.ScreenedName, s.Countries, s.SanctionHit, s.PEP, s.CountryHit,
+		s.RiskScore, s.Matches, s.ClearedBy, s.ClearedAt, s.ClearReason, s.ScreenedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create aml screening")
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.users SET risk_score = $1, updated_at = $2 WHERE id = $3
+	`, s.RiskScore, s.ScreenedAt, s.UserID); err != nil {
+		return errors.Wrap(err, "failed to update user risk score")
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit aml screening")
+}
+
+// LatestScreening returns the user's most recent screening, or nil if they
+// were never screened.
+func (r *KYCRepository) LatestScreening(ctx context.Context, userID uuid.UUID) (*domain.AMLScreening, error) {
+	var s domain.AMLScreening
+	err := r.db.GetContext(ctx, &s, `
+		SELECT `+amlScreeningColumns+` FROM customer_schema.aml_screenings
+		WHERE user_id = $1
+		ORDER BY screened_at DESC
+		LIMIT 1
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get aml screening")
+	}
+	return &s, nil
+}
+
+// ClearScreening marks an uncleared sanctions hit a false positive. It
+// reports false if the screening has no such hit.
+func (r *KYCRepository) ClearScreening(ctx context.Context, id, clearedBy uuid.UUID, reason string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.aml_screenings
+		SET cleared_by = $1, cleared_at = $2, clear_reason = $3
+		WHERE id = $4 AND sanction_hit AND cleared_at IS NULL
+	`, clearedBy, time.Now(), reason, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to clear aml screening")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// sendingTypes are the transaction types that count as a user sending money
+// for the funnel and retention aggregates.
+const sendingTypes = `('payment', 'transfer')`
+
+// AnalyticsRepository maintains the daily admin analytics aggregates.
+type AnalyticsRepository struct {
+	db *sqlx.DB
+}
+
+func NewAnalyticsRepository(db *sqlx.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db}
+}
+
+// RefreshFunnel recomputes the funnel rows for users who registered in
+// [from, to).
+func (r *AnalyticsRepository) RefreshFunnel(ctx context.Context, from, to time.Time) error {
+	query := `
+		INSERT INTO admin_schema.analytics_funnel_daily
+			(day, corridor, registered, kyc_verified, first_payment, computed_at)
+		SELECT
+			(u.created_at AT TIME ZONE 'UTC')::date,
+			u.country_code,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE u.kyc_status = 'verified'),
+			COUNT(*) FILTER (WHERE EXISTS (
+				SELECT 1 FROM customer_schema.transactions t
+				WHERE t.sender_id = u.id
+				  AND t.status = 'completed'
+				  AND t.transaction_type IN ` + sendingTypes + `
+			)),
+			NOW()
+		FROM customer_schema.users u
+		WHERE u.user_type <> 'admin'
+		  AND u.created_at >= $1 AND u.created_at < $2
+		GROUP BY 1, 2
+		ON CONFLICT (day, corridor) DO UPDATE SET
+			registered = EXCLUDED.registered,
+			kyc_verified = EXCLUDED.kyc_verified,
+			first_payment = EXCLUDED.first_payment,
+			computed_at = EXCLUDED.computed_at
+	`
+	_, err := r.db.ExecContext(ctx, query, from, to)
+	return errors.Wrap(err, "failed to refresh analytics funnel")
+}
+
+// RefreshRetention recomputes the retention cells for sender activity in
+// [from, to). Only senders active in the window are looked up for their
+// cohort month, which keeps the daily run proportional to recent activity.
+func (r *AnalyticsRepository) RefreshRetention(ctx context.Context, from, to time.Time) error {
+	query := `
+		WITH activity AS (
+			SELECT DISTINCT t.sender_id,
+				date_trunc('month', t.created_at AT TIME ZONE 'UTC')::date AS activity_month
+			FROM customer_schema.transactions t
+			WHERE t.status = 'completed'
+			  AND t.transaction_type IN ` + sendingTypes + `
+			  AND t.created_at >= $1 AND t.created_at < $2
+		), cohorts AS (
+			SELECT t.sender_id,
+				date_trunc('month', MIN(t.created_at) AT TIME ZONE 'UTC')::date AS cohort_month
+			FROM customer_schema.transactions t
+			WHERE t.status = 'completed'
+			  AND t.transaction_type IN ` + sendingTypes + `
+			  AND t.sender_id IN (SELECT sender_id FROM activity)
+			GROUP BY t.sender_id
+		)
+		INSERT INTO admin_schema.analytics_sender_retention
+			(cohort_month, activity_month, corridor, senders, computed_at)
+		SELECT c.cohort_month, a.activity_month, u.country_code, COUNT(*), NOW()
+		FROM activity a
+		JOIN cohorts c ON c.sender_id = a.sender_id
+		JOIN customer_schema.users u ON u.id = a.sender_id
+		GROUP BY 1, 2, 3
+		ON CONFLICT (cohort_month, activity_month, corridor) DO UPDATE SET
+			senders = EXCLUDED.senders,
+			computed_at = EXCLUDED.computed_at
+	`
+	_, err := r.db.ExecContext(ctx, query, from, to)
+	return errors.Wrap(err, "failed to refresh sender retention")
+}
+
+// ListFunnel returns the funnel rows for registration days in [from, to).
+// An empty corridor returns every corridor.
+func (r *AnalyticsRepository) ListFunnel(ctx context.Context, from, to time.Time, corridor string) ([]domain.FunnelDay, error) {
+	var days []domain.FunnelDay
+	query := `
+		SELECT day, corridor, registered, kyc_verified, first_payment, computed_at
+		FROM admin_schema.analytics_funnel_daily
+		WHERE day >= $1 AND day < $2 AND ($3 = '' OR corridor = $3)
+		ORDER BY day, corridor
+	`
+	if err := r.db.SelectContext(ctx, &days, query, from, to, corridor); err != nil {
+		return nil, errors.Wrap(err, "failed to list analytics funnel")
+	}
+	return days, nil
+}
+
+// ListRetention returns the retention cells of cohorts starting in
+// [from, to). An empty corridor returns every corridor.
+func (r *AnalyticsRepository) ListRetention(ctx context.Context, from, to time.Time, corridor string) ([]domain.RetentionCell, error) {
+	var cells []domain.RetentionCell
+	query := `
+		SELECT cohort_month, activity_month, corridor, senders, computed_at
+		FROM admin_schema.analytics_sender_retention
+		WHERE cohort_month >= $1 AND cohort_month < $2 AND ($3 = '' OR corridor = $3)
+		ORDER BY cohort_month, activity_month, corridor
+	`
+	if err := r.db.SelectContext(ctx, &cells, query, from, to, corridor); err != nil {
+		return nil, errors.Wrap(err, "failed to list sender retention")
+	}
+	return cells, nil
+}
@@ -17,6 +17,26 @@ type APIKeyRepository struct {
 	db *sqlx.DB
 }
 
+// apiKeyRow scans scopes, a TEXT[], which []string cannot.
+type apiKeyRow struct {
+	domain.APIKey
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+func (row apiKeyRow) key() domain.APIKey {
+	k := row.APIKey
+	k.Scopes = []string(row.Scopes)
+	return k
+}
+
+func apiKeys(rows []apiKeyRow) []domain.APIKey {
+	keys := make([]domain.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.key())
+	}
+	return keys
+}
+
 func NewAPIKeyRepository(db *sqlx.DB) *APIKeyRepository {
 	return &APIKeyRepository{db: db}
 }
@@ -25,15 +45,15 @@ func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error
 	query := `
 		INSERT INTO admin_schema.api_keys (
 			id, name, key_prefix, key_hash, scopes, is_active,
-			expires_at, created_by, created_at
+			expires_at, created_by, created_at, owner_id, environment
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
 		)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
 		key.ID, key.Name, key.KeyPrefix, key.KeyHash, pq.Array(key.Scopes),
-		key.IsActive, key.ExpiresAt, key.CreatedBy, key.CreatedAt,
+		key.IsActive, key.ExpiresAt, key.CreatedBy, key.CreatedAt, key.OwnerID, key.Environment,
 	)
 
 	if err != nil {
@@ -49,18 +69,26 @@ func (r *APIKeyRepository) List(ctx context.Context) ([]domain.APIKey, error) {
 		ORDER BY created_at DESC
 	`
 
-	var keys []domain.APIKey
-	err := r.db.SelectContext(ctx, &keys, query)
+	var rows []apiKeyRow
+	err := r.db.SelectContext(ctx, &rows, query)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list api keys")
 	}
+	return apiKeys(rows), nil
+}
 
-	// sqlx might not automatically scan TEXT[] into []string properly without pq.Array, 
-	// but let's see if domain.APIKey uses []string. 
-	// If it fails, we might need a custom scanner or use a struct with pq.StringArray.
-	// For now assuming standard sqlx behavior with postgres driver support.
-	
-	return keys, nil
+// ListByOwner returns the keys issued to a customer account, newest first.
+func (r *APIKeyRepository) ListByOwner(ctx context.Context, owner uuid.UUID) ([]domain.APIKey, error) {
+	var rows []apiKeyRow
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT * FROM admin_schema.api_keys
+		WHERE owner_id = $1
+		ORDER BY created_at DESC
+	`, owner)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list api keys")
+	}
+	return apiKeys(rows), nil
 }
 
 func (r *APIKeyRepository) GetByKeyHash(ctx context.Context, hash string) (*domain.APIKey, error) {
@@ -69,8 +97,8 @@ func (r *APIKeyRepository) GetByKeyHash(ctx context.Context, hash string) (*doma
 		WHERE key_hash = $1 AND is_active = true
 	`
 
-	var key domain.APIKey
-	err := r.db.GetContext(ctx, &key, query, hash)
+	var row apiKeyRow
+	err := r.db.GetContext(ctx, &row, query, hash)
 	if err == sql.ErrNoRows {
 		return nil, nil // Not found is not an error here, just nil
 	}
@@ -78,6 +106,8 @@ func (r *APIKeyRepository) GetByKeyHash(ctx context.Context, hash string) (*doma
 		return nil, errors.Wrap(err, "failed to get api key")
 	}
 	
+	key := row.key()
+
 	// Check expiry if set
 	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
 		return nil, nil // Expired
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// BalanceHistoryRepository keeps wallet balance snapshots and rebuilds
+// balances and limit usage at past moments.
+type BalanceHistoryRepository struct {
+	db *sqlx.DB
+}
+
+func NewBalanceHistoryRepository(db *sqlx.DB) *BalanceHistoryRepository {
+	return &BalanceHistoryRepository{db: db}
+}
+
+// TakeBalanceSnapshots copies every wallet's balances for the period
+// starting at periodStart, skipping wallets already copied for it. Balances
+// and last entries are read in one statement, so they agree.
+func (r *BalanceHistoryRepository) TakeBalanceSnapshots(ctx context.Context, periodStart time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.wallet_balance_snapshots (
+			wallet_id, period_start, taken_at, currency, available_balance, ledger_balance, reserved_balance,
+			last_entry_id, last_entry_at)
+		SELECT w.id, $1, NOW(), w.currency, COALESCE(w.available_balance, 0), COALESCE(w.ledger_balance, 0),
+			COALESCE(w.reserved_balance, 0), e.id, e.created_at
+		FROM customer_schema.wallets w
+		LEFT JOIN LATERAL (
+			SELECT id, created_at FROM customer_schema.ledger_entries
+			WHERE wallet_id = w.id
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		) e ON TRUE
+		ON CONFLICT (wallet_id, period_start) DO NOTHING
+	`, periodStart)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to take balance snapshots")
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (r *BalanceHistoryRepository) PruneBalanceSnapshots(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM customer_schema.wallet_balance_snapshots WHERE period_start < $1
+	`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to prune balance snapshots")
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// WalletBalancesAt rebuilds the balances of the user's wallets that existed
+// at at: the latest snapshot taken by then plus the entries written after
+// the last one it includes, up to at.
+func (r *BalanceHistoryRepository) WalletBalancesAt(ctx context.Context, userID uuid.UUID, at time.Time) ([]domain.WalletBalanceAt, error) {
+	balances := []domain.WalletBalanceAt{}
+	err := r.db.SelectContext(ctx, &balances, `
+		SELECT w.id AS wallet_id, w.currency,
+			COALESCE(s.available_balance, 0) + COALESCE(m.movement, 0) AS balance,
+			s.taken_at AS snapshot_at, s.available_balance AS snapshot_balance, s.reserved_balance AS snapshot_reserved,
+			COALESCE(m.entries, 0) AS entries_replayed,
+			last.created_at AS last_entry_at, last.balance_after AS entry_balance
+		FROM customer_schema.wallets w
+		LEFT JOIN LATERAL (
+			SELECT taken_at, available_balance, reserved_balance, last_entry_id, last_entry_at
+			FROM customer_schema.wallet_balance_snapshots
+			WHERE wallet_id = w.id AND taken_at <= $2
+			ORDER BY taken_at DESC
+			LIMIT 1
+		) s ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT SUM(CASE WHEN e.entry_type = 'credit' THEN e.amount ELSE -e.amount END) AS movement,
+				COUNT(*) AS entries
+			FROM customer_schema.ledger_entries e
+			WHERE e.wallet_id = w.id AND e.created_at <= $2
+				AND (s.last_entry_at IS NULL OR (e.created_at, e.id) > (s.last_entry_at, s.last_entry_id))
+		) m ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT created_at, balance_after FROM customer_schema.ledger_entries
+			WHERE wallet_id = w.id AND created_at <= $2
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		) last ON TRUE
+		WHERE w.user_id = $1 AND w.created_at <= $2
+		ORDER BY w.currency
+	`, userID, at)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to rebuild wallet balances")
+	}
+	return balances, nil
+}
+
+// countedAt keeps the payments t that counted against limits at the
+// placeholder at: those not yet failed or cancelled then. A payment's
+// failure is dated by its event, or by its last update when it has none.
+func countedAt(at string) string {
+	return `
+		AND NOT (t.status IN ('failed', 'cancelled') AND COALESCE((
+			SELECT MIN(e.created_at) FROM customer_schema.transaction_events e
+			WHERE e.transaction_id = t.id AND e.event_type IN ('failed', 'cancelled')
+		), t.updated_at) <= ` + at + `)`
+}
+
+// DailyTotalAt is GetDailyTotal as it stood at at.
+func (r *BalanceHistoryRepository) DailyTotalAt(ctx context.Context, userID uuid.UUID, currency domain.Currency, at time.Time) (decimal.Decimal, error) {
+	var total decimal.Decimal
+	err := r.db.GetContext(ctx, &total, `
+		SELECT COALESCE(SUM(t.amount), 0)
+		FROM customer_schema.transactions t
+		WHERE t.sender_id = $1 AND t.currency = $2
+			AND t.created_at > $3::timestamptz - INTERVAL '24 hours' AND t.created_at <= $3`+countedAt("$3"),
+		userID, currency, at)
+	if err != nil {
+		return decimal.Zero, errors.Wrap(err, "failed to get daily total")
+	}
+	return total, nil
+}
+
+// HourlyCountAt is GetHourlyCount as it stood at at.
+func (r *BalanceHistoryRepository) HourlyCountAt(ctx context.Context, userID uuid.UUID, at time.Time) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `
+		SELECT COUNT(*)
+		FROM customer_schema.transactions t
+		WHERE t.sender_id = $1
+			AND t.created_at > $2::timestamptz - INTERVAL '1 hour' AND t.created_at <= $2`+countedAt("$2"),
+		userID, at)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get hourly count")
+	}
+	return count, nil
+}
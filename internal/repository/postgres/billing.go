@@ -0,0 +1,227 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/billing"
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+type BillingRepository struct {
+	db *sqlx.DB
+}
+
+func NewBillingRepository(db *sqlx.DB) *BillingRepository {
+	return &BillingRepository{db: db}
+}
+
+const invoiceColumns = `
+	id, number, user_id, currency, period_start, period_end, total, status,
+	collection_method, due_at, attempts, next_attempt_at, last_error, paid_at,
+	payment_reference, transaction_id, created_at, updated_at`
+
+func (r *BillingRepository) AddUsage(ctx context.Context, userID uuid.UUID, metric domain.UsageMetric, currency domain.Currency, periodStart time.Time, quantity decimal.Decimal) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.billing_usage (user_id, metric, currency, period_start, quantity)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, metric, currency, period_start)
+		DO UPDATE SET quantity = billing_usage.quantity + EXCLUDED.quantity
+	`, userID, metric, currency, periodStart, quantity)
+	return errors.Wrap(err, "failed to record usage")
+}
+
+func (r *BillingRepository) ListUsage(ctx context.Context, userID uuid.UUID, periodStart time.Time) ([]domain.UsageTotal, error) {
+	usage := []domain.UsageTotal{}
+	err := r.db.SelectContext(ctx, &usage, `
+		SELECT user_id, metric, currency, period_start, quantity
+		FROM customer_schema.billing_usage
+		WHERE user_id = $1 AND period_start = $2
+		ORDER BY metric, currency
+	`, userID, periodStart)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list usage")
+	}
+	return usage, nil
+}
+
+func (r *BillingRepository) AddCharge(ctx context.Context, c *domain.BillingCharge) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.billing_charges (id, user_id, amount, currency, description, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, c.ID, c.UserID, c.Amount, c.Currency, c.Description, c.CreatedAt)
+	return errors.Wrap(err, "failed to add billing charge")
+}
+
+func (r *BillingRepository) ListUnbilledCharges(ctx context.Context, userID uuid.UUID, before time.Time) ([]domain.BillingCharge, error) {
+	charges := []domain.BillingCharge{}
+	err := r.db.SelectContext(ctx, &charges, `
+		SELECT id, user_id, amount, currency, description, invoice_id, created_at
+		FROM customer_schema.billing_charges
+		WHERE user_id = $1 AND invoice_id IS NULL AND created_at < $2
+		ORDER BY created_at
+	`, userID, before)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list billing charges")
+	}
+	return charges, nil
+}
+
+func (r *BillingRepository) ListBillableUsers(ctx context.Context, periodStart, periodEnd time.Time) ([]uuid.UUID, error) {
+	users := []uuid.UUID{}
+	err := r.db.SelectContext(ctx, &users, `
+		SELECT user_id FROM customer_schema.plan_subscriptions
+		WHERE plan <> $3 OR pending_plan IS NOT NULL
+		UNION
+		SELECT user_id FROM customer_schema.billing_usage
+		WHERE period_start = $1
+		UNION
+		SELECT user_id FROM customer_schema.billing_charges
+		WHERE invoice_id IS NULL AND created_at < $2
+	`, periodStart, periodEnd, domain.PlanConsumer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list billable users")
+	}
+	return users, nil
+}
+
+func (r *BillingRepository) CreateInvoice(ctx context.Context, inv *domain.Invoice, chargeIDs []uuid.UUID) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.invoices (`+invoiceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		ON CONFLICT (user_id, period_start, currency) DO NOTHING
+	`,
+		inv.ID, inv.Number, inv.UserID, inv.Currency, inv.PeriodStart, inv.PeriodEnd, inv.Total, inv.Status,
+		inv.CollectionMethod, inv.DueAt, inv.Attempts, inv.NextAttemptAt, inv.LastError, inv.PaidAt,
+		inv.PaymentReference, inv.TransactionID, inv.CreatedAt, inv.UpdatedAt,
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create invoice")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	for _, l := range inv.Lines {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO customer_schema.invoice_lines (id, invoice_id, kind, description, quantity, unit_price, amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, l.ID, inv.ID, l.Kind, l.Description, l.Quantity, l.UnitPrice, l.Amount)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to create invoice line")
+		}
+	}
+
+	if len(chargeIDs) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE customer_schema.billing_charges SET invoice_id = $1
+			WHERE id = ANY($2) AND invoice_id IS NULL
+		`, inv.ID, pq.Array(chargeIDs))
+		if err != nil {
+			return false, errors.Wrap(err, "failed to bill charges")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit invoice")
+	}
+	return true, nil
+}
+
+func (r *BillingRepository) GetInvoice(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
+	var inv domain.Invoice
+	err := r.db.GetContext(ctx, &inv, `SELECT `+invoiceColumns+` FROM customer_schema.invoices WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invoice")
+	}
+	inv.Lines = []domain.InvoiceLine{}
+	err = r.db.SelectContext(ctx, &inv.Lines, `
+		SELECT id, invoice_id, kind, description, quantity, unit_price, amount
+		FROM customer_schema.invoice_lines
+		WHERE invoice_id = $1
+		ORDER BY kind, description
+	`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invoice lines")
+	}
+	return &inv, nil
+}
+
+func (r *BillingRepository) ListInvoices(ctx context.Context, filter billing.InvoiceFilter) ([]domain.Invoice, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where = append(where, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		where = append(where, fmt.Sprintf("status = $%d", len(args)))
+	}
+	query := `SELECT ` + invoiceColumns + ` FROM customer_schema.invoices`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY period_start DESC, created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	invoices := []domain.Invoice{}
+	if err := r.db.SelectContext(ctx, &invoices, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to list invoices")
+	}
+	return invoices, nil
+}
+
+func (r *BillingRepository) ListDueInvoices(ctx context.Context, now time.Time, limit int) ([]domain.Invoice, error) {
+	invoices := []domain.Invoice{}
+	err := r.db.SelectContext(ctx, &invoices, `
+		SELECT `+invoiceColumns+` FROM customer_schema.invoices
+		WHERE (collection_method = 'wallet' AND status IN ('open', 'past_due') AND next_attempt_at <= $1)
+		   OR (collection_method = 'external' AND status = 'open' AND due_at <= $1)
+		ORDER BY due_at
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list due invoices")
+	}
+	return invoices, nil
+}
+
+func (r *BillingRepository) UpdateInvoice(ctx context.Context, inv *domain.Invoice) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.invoices SET
+			status = $2, collection_method = $3, due_at = $4, attempts = $5,
+			next_attempt_at = $6, last_error = $7, paid_at = $8,
+			payment_reference = $9, transaction_id = $10, updated_at = $11
+		WHERE id = $1 AND status IN ('open', 'past_due')
+	`,
+		inv.ID, inv.Status, inv.CollectionMethod, inv.DueAt, inv.Attempts,
+		inv.NextAttemptAt, inv.LastError, inv.PaidAt,
+		inv.PaymentReference, inv.TransactionID, inv.UpdatedAt,
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update invoice")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillingRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewBillingRepository(db)
+	march := time.Date(1990, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := march.AddDate(0, 1, 0)
+
+	t.Run("usage adds up per month", func(t *testing.T) {
+		user := testUser(t, db, march)
+		require.NoError(t, repo.AddUsage(ctx, user, domain.UsageAPICalls, "", march, decimal.NewFromInt(40)))
+		require.NoError(t, repo.AddUsage(ctx, user, domain.UsageAPICalls, "", march, decimal.NewFromInt(2)))
+		require.NoError(t, repo.AddUsage(ctx, user, domain.UsageAPICalls, "", april, decimal.NewFromInt(7)))
+
+		usage, err := repo.ListUsage(ctx, user, march)
+		require.NoError(t, err)
+		require.Len(t, usage, 1)
+		assert.True(t, usage[0].Quantity.Equal(decimal.NewFromInt(42)), usage[0].Quantity.String())
+
+		users, err := repo.ListBillableUsers(ctx, march, april)
+		require.NoError(t, err)
+		assert.Contains(t, users, user)
+	})
+
+	t.Run("an invoice bills its charges once", func(t *testing.T) {
+		user := testUser(t, db, march)
+		billed := testCharge(t, repo, user, 2500, march.Add(24*time.Hour))
+		later := testCharge(t, repo, user, 100, april.Add(time.Hour))
+
+		charges, err := repo.ListUnbilledCharges(ctx, user, april)
+		require.NoError(t, err)
+		require.Len(t, charges, 1, "the later charge waits for April")
+		assert.Equal(t, billed, charges[0].ID)
+
+		inv := testBillingInvoice(user, march, march.Add(31*24*time.Hour))
+		created, err := repo.CreateInvoice(ctx, inv, []uuid.UUID{billed})
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		again := testBillingInvoice(user, march, march.Add(31*24*time.Hour))
+		created, err = repo.CreateInvoice(ctx, again, []uuid.UUID{later})
+		require.NoError(t, err)
+		assert.False(t, created, "one invoice per month and currency")
+		missing, err := repo.GetInvoice(ctx, again.ID)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+
+		charges, err = repo.ListUnbilledCharges(ctx, user, april.Add(24*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, charges, 1, "nothing of the refused invoice is stored")
+		assert.Equal(t, later, charges[0].ID)
+
+		got, err := repo.GetInvoice(ctx, inv.ID)
+		require.NoError(t, err)
+		assert.Equal(t, inv.Number, got.Number)
+		assert.True(t, got.Total.Equal(inv.Total))
+		require.Len(t, got.Lines, 1)
+		assert.Equal(t, domain.InvoiceLineCharge, got.Lines[0].Kind)
+	})
+
+	t.Run("due invoices", func(t *testing.T) {
+		issued := april.Add(time.Hour)
+		now := issued.Add(48 * time.Hour)
+		user := testUser(t, db, march)
+		invoices := map[string]*domain.Invoice{}
+		for i, name := range []string{"debit due", "debit later", "external due", "external later", "paid"} {
+			// One per month, as an account only has one per month
+			inv := testBillingInvoice(user, march.AddDate(0, -i, 0), issued)
+			invoices[name] = inv
+		}
+		later := now.Add(time.Hour)
+		invoices["debit later"].NextAttemptAt = &later
+		invoices["external due"].CollectionMethod, invoices["external due"].NextAttemptAt = domain.CollectionExternal, nil
+		invoices["external later"].CollectionMethod, invoices["external later"].NextAttemptAt = domain.CollectionExternal, nil
+		invoices["external later"].DueAt = later
+		invoices["paid"].Status, invoices["paid"].PaidAt, invoices["paid"].NextAttemptAt = domain.InvoiceStatusPaid, &issued, nil
+		for _, inv := range invoices {
+			created, err := repo.CreateInvoice(ctx, inv, nil)
+			require.NoError(t, err)
+			require.True(t, created)
+		}
+
+		due, err := repo.ListDueInvoices(ctx, now, 100)
+		require.NoError(t, err)
+		var ids []uuid.UUID
+		for _, inv := range due {
+			if inv.UserID == user {
+				ids = append(ids, inv.ID)
+			}
+		}
+		assert.ElementsMatch(t, []uuid.UUID{invoices["debit due"].ID, invoices["external due"].ID}, ids)
+
+		paid := *invoices["debit due"]
+		paid.Status, paid.PaidAt, paid.NextAttemptAt, paid.UpdatedAt = domain.InvoiceStatusPaid, &now, nil, now
+		updated, err := repo.UpdateInvoice(ctx, &paid)
+		require.NoError(t, err)
+		assert.True(t, updated)
+
+		late := *invoices["debit due"]
+		late.Status, late.Attempts = domain.InvoiceStatusPastDue, 1
+		updated, err = repo.UpdateInvoice(ctx, &late)
+		require.NoError(t, err)
+		assert.False(t, updated, "settled invoices stay settled")
+		got, err := repo.GetInvoice(ctx, paid.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.InvoiceStatusPaid, got.Status)
+	})
+}
+
+// testCharge adds a charge of amount MWK for user. It goes with the user.
+func testCharge(t *testing.T, repo *BillingRepository, user uuid.UUID, amount int64, created time.Time) uuid.UUID {
+	t.Helper()
+	c := &domain.BillingCharge{
+		ID:          uuid.New(),
+		UserID:      user,
+		Amount:      decimal.NewFromInt(amount),
+		Currency:    domain.MWK,
+		Description: "test",
+		CreatedAt:   created,
+	}
+	require.NoError(t, repo.AddCharge(context.Background(), c))
+	return c.ID
+}
+
+// testBillingInvoice is an unsaved, open MWK wallet invoice for the month
+// starting month, first due at issued.
+func testBillingInvoice(user uuid.UUID, month, issued time.Time) *domain.Invoice {
+	id := uuid.New()
+	amount := decimal.NewFromInt(2500)
+	return &domain.Invoice{
+		ID:               id,
+		Number:           "TEST-" + id.String()[:8],
+		UserID:           user,
+		Currency:         domain.MWK,
+		PeriodStart:      month,
+		PeriodEnd:        month.AddDate(0, 1, 0),
+		Total:            amount,
+		Status:           domain.InvoiceStatusOpen,
+		CollectionMethod: domain.CollectionWallet,
+		DueAt:            issued,
+		NextAttemptAt:    &issued,
+		CreatedAt:        issued,
+		UpdatedAt:        issued,
+		Lines: []domain.InvoiceLine{{
+			ID:          uuid.New(),
+			InvoiceID:   id,
+			Kind:        domain.InvoiceLineCharge,
+			Description: "test",
+			Quantity:    decimal.NewFromInt(1),
+			UnitPrice:   amount,
+			Amount:      amount,
+		}},
+	}
+}
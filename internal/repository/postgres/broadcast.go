@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/broadcast"
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// broadcastLease is how long a broadcast may stay in sending before another
+// worker assumes the previous one died and resumes it.
+const broadcastLease = 15 * time.Minute
+
+const broadcastColumns = `
+	id, title, message, segment, segment_id, channels, status, scheduled_at,
+	started_at, completed_at, created_by, created_at, updated_at
+`
+
+type BroadcastRepository struct {
+	db *sqlx.DB
+}
+
+func NewBroadcastRepository(db *sqlx.DB) *BroadcastRepository {
+	return &BroadcastRepository{db: db}
+}
+
+func (r *BroadcastRepository) CreateBroadcast(ctx context.Context, b *domain.Broadcast) error {
+	query := `
+		INSERT INTO admin_schema.broadcasts (` + broadcastColumns + `)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		b.ID, b.Title, b.Message, b.Segment, b.SegmentID, b.Channels, b.Status, b.ScheduledAt,
+		b.StartedAt, b.CompletedAt, b.CreatedBy, b.CreatedAt, b.UpdatedAt,
+	)
+	return errors.Wrap(err, "failed to create broadcast")
+}
+
+func (r *BroadcastRepository) GetBroadcast(ctx context.Context, id uuid.UUID) (*domain.Broadcast, error) {
+	var b domain.Broadcast
+	query := `SELECT ` + broadcastColumns + ` FROM admin_schema.broadcasts WHERE id = $1`
+	err := r.db.GetContext(ctx, &b, query, id)
+	if err == sql.ErrNoRows {
+		return nil, broadcast.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get broadcast")
+	}
+	return &b, nil
+}
+
+func (r *BroadcastRepository) ListBroadcasts(ctx context.Context, limit, offset int) ([]domain.Broadcast, int, error) {
+	var items []domain.Broadcast
+	query := `
+		SELECT ` + broadcastColumns + `
+		FROM admin_schema.broadcasts
+		ORDER BY scheduled_at DESC
+		LIMIT $1 OFFSET $2
+	`
+	if err := r.db.SelectContext(ctx, &items, query, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list broadcasts")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.broadcasts`); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count broadcasts")
+	}
+	return items, total, nil
+}
+
+func (r *BroadcastRepository) CancelBroadcast(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE admin_schema.broadcasts
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1 AND status = $3
+	`
+	res, err := r.db.ExecContext(ctx, query, id, domain.BroadcastStatusCancelled, domain.BroadcastStatusScheduled)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to cancel broadcast")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to cancel broadcast")
+	}
+	return n > 0, nil
+}
+
+func (r *BroadcastRepository) ClaimDueBroadcasts(ctx context.Context, now time.Time, limit int) ([]domain.Broadcast, error) {
+	var items []domain.Broadcast
+	query := `
+		UPDATE admin_schema.broadcasts
+		SET status = $1, started_at = $3, updated_at = $3
+		WHERE id IN (
+			SELECT id FROM admin_schema.broadcasts
+			WHERE (status = $2 AND scheduled_at <= $3)
+			   OR (status = $1 AND started_at < $4)
+			ORDER BY scheduled_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + broadcastColumns
+	err := r.db.SelectContext(ctx, &items, query,
+		domain.BroadcastStatusSending, domain.BroadcastStatusScheduled, now, now.Add(-broadcastLease), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim due broadcasts")
+	}
+	return items, nil
+}
+
+func (r *BroadcastRepository) CompleteBroadcast(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE admin_schema.broadcasts
+		SET status = $2, completed_at = $3, updated_at = $3
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, domain.BroadcastStatusSent, at)
+	return errors.Wrap(err, "failed to complete broadcast")
+}
+
+func (r *BroadcastRepository) ListSegmentRecipients(ctx context.Context, broadcastID uuid.UUID, segment domain.BroadcastSegment, limit int) ([]uuid.UUID, error) {
+	where, args := buildSegmentWhere(segment, []interface{}{broadcastID, limit})
+	query := `
+		SELECT u.id
+		FROM customer_schema.users u
+		` + where + `
+		AND NOT EXISTS (
+			SELECT 1 FROM admin_schema.broadcast_deliveries d
+			WHERE d.broadcast_id = $1 AND d.user_id = u.id
+		)
+		ORDER BY u.id
+		LIMIT $2
+	`
+	var ids []uuid.UUID
+	if err := r.db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to list broadcast recipients")
+	}
+	return ids, nil
+}
+
+func (r *BroadcastRepository) CountSegmentRecipients(ctx context.Context, segment domain.BroadcastSegment) (int, error) {
+	where, args := buildSegmentWhere(segment, nil)
+	var n int
+	if err := r.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM customer_schema.users u `+where, args...); err != nil {
+		return 0, errors.Wrap(err, "failed to count broadcast recipients")
+	}
+	return n, nil
+}
+
+func (r *BroadcastRepository) CreateDelivery(ctx context.Context, d *domain.BroadcastDelivery) error {
+	query := `
+		INSERT INTO admin_schema.broadcast_deliveries (
+			id, broadcast_id, user_id, notification_id, status, error, created_at
+		) VALUES ($1,$2,$3,$4,$5,$6,$7)
+		ON CONFLICT (broadcast_id, user_id) DO NOTHING
+	`
+	_, err := r.db.ExecContext(ctx, query, d.ID, d.BroadcastID, d.UserID, d.NotificationID, d.Status, d.Error, d.CreatedAt)
+	return errors.Wrap(err, "failed to record broadcast delivery")
+}
+
+func (r *BroadcastRepository) GetBroadcastStats(ctx context.Context, id uuid.UUID) (*domain.BroadcastStats, error) {
+	var stats domain.BroadcastStats
+	query := `
+		SELECT
+			COUNT(*) AS recipients,
+			COUNT(*) FILTER (WHERE d.status = 'delivered') AS delivered,
+			COUNT(*) FILTER (WHERE d.status = 'failed') AS failed,
+			COUNT(*) FILTER (WHERE n.is_read) AS read
+		FROM admin_schema.broadcast_deliveries d
+		LEFT JOIN customer_schema.notifications n ON n.id = d.notification_id
+		WHERE d.broadcast_id = $1
+	`
+	if err := r.db.GetContext(ctx, &stats, query, id); err != nil {
+		return nil, errors.Wrap(err, "failed to get broadcast stats")
+	}
+	return &stats, nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const bulkFileColumns = `
+	id, user_id, file_name, format, format_version, reference, sha256, size,
+	status, payment_count, errors, result, received_at, processed_at
+`
+
+type BulkFileRepository struct {
+	db *sqlx.DB
+}
+
+func NewBulkFileRepository(db *sqlx.DB) *BulkFileRepository {
+	return &BulkFileRepository{db: db}
+}
+
+func (r *BulkFileRepository) CreateBulkFile(ctx context.Context, f *domain.BulkFile) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.bulk_files (`+bulkFileColumns+`, content)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15)
+	`, f.ID, f.UserID, f.FileName, f.Format, f.FormatVersion, f.Reference, f.SHA256, f.Size,
+		f.Status, f.PaymentCount, nullJSON(f.Errors), nullJSON(f.Result), f.ReceivedAt, f.ProcessedAt, f.Content)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return errors.ErrDuplicateBulkFile
+	}
+	return errors.Wrap(err, "failed to record bulk file")
+}
+
+func (r *BulkFileRepository) CompleteBulkFile(ctx context.Context, id uuid.UUID, result domain.JSONPayload, processedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.bulk_files
+		SET status = $2, result = $3, processed_at = $4
+		WHERE id = $1
+	`, id, domain.BulkFileStatusProcessed, nullJSON(result), processedAt)
+	return errors.Wrap(err, "failed to complete bulk file")
+}
+
+func (r *BulkFileRepository) GetBulkFile(ctx context.Context, id uuid.UUID) (*domain.BulkFile, error) {
+	var f domain.BulkFile
+	err := r.db.GetContext(ctx, &f, `SELECT `+bulkFileColumns+`, content FROM customer_schema.bulk_files WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrBulkFileNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get bulk file")
+	}
+	return &f, nil
+}
+
+func (r *BulkFileRepository) ListBulkFiles(ctx context.Context, userID *uuid.UUID, limit, offset int) ([]domain.BulkFile, int, error) {
+	where := ""
+	args := []interface{}{limit, offset}
+	if userID != nil {
+		where = "WHERE user_id = $3"
+		args = append(args, *userID)
+	}
+	items := []domain.BulkFile{}
+	query := `
+		SELECT ` + bulkFileColumns + `
+		FROM customer_schema.bulk_files
+		` + where + `
+		ORDER BY received_at DESC
+		LIMIT $1 OFFSET $2
+	`
+	if err := r.db.SelectContext(ctx, &items, query, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list bulk files")
+	}
+	var total int
+	countWhere := strings.Replace(where, "$3", "$1", 1)
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.bulk_files `+countWhere, args[2:]...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count bulk files")
+	}
+	return items, total, nil
+}
+
+// nullJSON stores an empty document as NULL rather than invalid JSON.
+func nullJSON(p domain.JSONPayload) interface{} {
+	if len(p) == 0 {
+		return nil
+	}
+	return string(p)
+}
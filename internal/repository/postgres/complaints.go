@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/complaint"
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const complaintColumns = `
+	c.id, c.reference, c.user_id, c.transaction_id, c.complainant_name, c.complainant_contact,
+	c.channel, c.category, c.language, c.subject, c.description, c.amount, c.currency, c.status,
+	c.received_at, c.acknowledge_by, c.acknowledged_at, c.resolve_by, c.resolved_at, c.outcome,
+	c.root_cause, c.resolution, c.redress_amount, c.assigned_to, c.created_by, c.updated_at
+`
+
+// ComplaintRepository stores the complaints register and its quarterly
+// returns.
+type ComplaintRepository struct {
+	db *sqlx.DB
+}
+
+func NewComplaintRepository(db *sqlx.DB) *ComplaintRepository {
+	return &ComplaintRepository{db: db}
+}
+
+func (r *ComplaintRepository) Create(ctx context.Context, c *domain.Complaint) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.complaints (
+			id, reference, user_id, transaction_id, complainant_name, complainant_contact,
+			channel, category, language, subject, description, amount, currency, status,
+			received_at, acknowledge_by, acknowledged_at, resolve_by, resolved_at, outcome,
+			root_cause, resolution, redress_amount, assigned_to, created_by, updated_at
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26)
+	`, c.ID, c.Reference, c.UserID, c.TransactionID, c.ComplainantName, c.ComplainantContact,
+		c.Channel, c.Category, c.Language, c.Subject, c.Description, c.Amount, c.Currency, c.Status,
+		c.ReceivedAt, c.AcknowledgeBy, c.AcknowledgedAt, c.ResolveBy, c.ResolvedAt, c.Outcome,
+		c.RootCause, c.Resolution, c.RedressAmount, c.AssignedTo, c.CreatedBy, c.UpdatedAt)
+	return errors.Wrap(err, "failed to create complaint")
+}
+
+func (r *ComplaintRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Complaint, error) {
+	var c domain.Complaint
+	err := r.db.GetContext(ctx, &c, `SELECT `+complaintColumns+` FROM customer_schema.complaints c WHERE c.id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, complaint.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get complaint")
+	}
+	return &c, nil
+}
+
+func (r *ComplaintRepository) List(ctx context.Context, filter domain.ComplaintFilter, now time.Time, limit, offset int) ([]domain.Complaint, int, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where = append(where, fmt.Sprintf("c.user_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		where = append(where, fmt.Sprintf("c.status = $%d", len(args)))
+	}
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		where = append(where, fmt.Sprintf("c.category = $%d", len(args)))
+	}
+	if filter.Overdue {
+		args = append(args, now)
+		where = append(where, fmt.Sprintf(
+			"((c.status = 'received' AND c.acknowledge_by < $%[1]d) OR (c.status <> 'resolved' AND c.resolve_by < $%[1]d))", len(args)))
+	}
+	from := ` FROM customer_schema.complaints c`
+	if len(where) > 0 {
+		from += ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*)`+from, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count complaints")
+	}
+	items := []domain.Complaint{}
+	query := `SELECT ` + complaintColumns + from +
+		fmt.Sprintf(` ORDER BY c.received_at DESC LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	if err := r.db.SelectContext(ctx, &items, query, append(args, limit, offset)...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list complaints")
+	}
+	return items, total, nil
+}
+
+func (r *ComplaintRepository) Update(ctx context.Context, c *domain.Complaint, from domain.ComplaintStatus) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.complaints
+		SET status = $3, acknowledged_at = $4, resolved_at = $5, outcome = $6, root_cause = $7,
+			resolution = $8, redress_amount = $9, assigned_to = $10, updated_at = $11
+		WHERE id = $1 AND status = $2
+	`, c.ID, from, c.Status, c.AcknowledgedAt, c.ResolvedAt, c.Outcome, c.RootCause,
+		c.Resolution, c.RedressAmount, c.AssignedTo, c.UpdatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update complaint")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update complaint")
+	}
+	return n > 0, nil
+}
+
+func (r *ComplaintRepository) ReturnLines(ctx context.Context, from, to time.Time) ([]domain.ComplaintReturnLine, error) {
+	lines := []domain.ComplaintReturnLine{}
+	err := r.db.SelectContext(ctx, &lines, `
+		SELECT `+complaintColumns+`,
+			COALESCE(u.user_type, '') AS user_type,
+			COALESCE(NULLIF(t.reference_number, ''), t.reference, '') AS transaction_reference
+		FROM customer_schema.complaints c
+		LEFT JOIN customer_schema.users u ON u.id = c.user_id
+		LEFT JOIN customer_schema.transactions t ON t.id = c.transaction_id
+		WHERE c.received_at < $2 AND (c.resolved_at IS NULL OR c.resolved_at >= $1)
+		ORDER BY c.received_at
+	`, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list complaints for return")
+	}
+	return lines, nil
+}
+
+func (r *ComplaintRepository) SaveReturn(ctx context.Context, ret *domain.ComplaintReturn) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.complaint_returns (period, period_start, period_end, complaints, content, generated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (period) DO NOTHING
+	`, ret.Period, ret.PeriodStart, ret.PeriodEnd, ret.Complaints, ret.Content, ret.GeneratedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to save complaints return")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to save complaints return")
+	}
+	return n > 0, nil
+}
+
+func (r *ComplaintRepository) GetReturn(ctx context.Context, period string) (*domain.ComplaintReturn, error) {
+	var ret domain.ComplaintReturn
+	err := r.db.GetContext(ctx, &ret, `
+		SELECT period, period_start, period_end, complaints, content, generated_at
+		FROM customer_schema.complaint_returns WHERE period = $1
+	`, period)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get complaints return")
+	}
+	return &ret, nil
+}
+
+func (r *ComplaintRepository) ListReturns(ctx context.Context) ([]domain.ComplaintReturn, error) {
+	items := []domain.ComplaintReturn{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT period, period_start, period_end, complaints, generated_at
+		FROM customer_schema.complaint_returns ORDER BY period_start DESC
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list complaints returns")
+	}
+	return items, nil
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/complaint"
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplaintRepository_Register(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewComplaintRepository(db)
+	now := time.Date(1990, 2, 15, 9, 0, 0, 0, time.UTC)
+	userID := testUser(t, db, now)
+
+	// Received in the quarter, acknowledgement overdue
+	late := testComplaint(t, db, repo, &userID, now.Add(-72*time.Hour))
+	// Received before the quarter, still open and past resolution
+	old := testComplaint(t, db, repo, &userID, time.Date(1989, 11, 1, 9, 0, 0, 0, time.UTC))
+	old.Status, old.AcknowledgedAt = domain.ComplaintAcknowledged, &old.ReceivedAt
+	updated, err := repo.Update(ctx, old, domain.ComplaintReceived)
+	require.NoError(t, err)
+	require.True(t, updated)
+	// Received in time, not yet due
+	fresh := testComplaint(t, db, repo, &userID, now.Add(-time.Hour))
+
+	t.Run("update is conditional on the status", func(t *testing.T) {
+		stale := *old
+		stale.Status = domain.ComplaintResolved
+		updated, err := repo.Update(ctx, &stale, domain.ComplaintReceived)
+		require.NoError(t, err)
+		assert.False(t, updated, "already acknowledged")
+
+		got, err := repo.Get(ctx, old.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.ComplaintAcknowledged, got.Status)
+
+		_, err = repo.Get(ctx, uuid.New())
+		assert.ErrorIs(t, err, complaint.ErrNotFound)
+	})
+
+	t.Run("overdue", func(t *testing.T) {
+		items, total, err := repo.List(ctx, domain.ComplaintFilter{UserID: &userID, Overdue: true}, now, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.ElementsMatch(t, []uuid.UUID{late.ID, old.ID}, complaintIDs(items))
+
+		items, total, err = repo.List(ctx, domain.ComplaintFilter{UserID: &userID}, now, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []uuid.UUID{fresh.ID, late.ID, old.ID}, complaintIDs(items), "newest first")
+	})
+
+	t.Run("return lines cover the quarter", func(t *testing.T) {
+		// Resolved before the quarter began
+		done := testComplaint(t, db, repo, &userID, time.Date(1989, 10, 1, 9, 0, 0, 0, time.UTC))
+		resolvedAt := time.Date(1989, 12, 1, 9, 0, 0, 0, time.UTC)
+		done.Status, done.AcknowledgedAt, done.ResolvedAt = domain.ComplaintResolved, &resolvedAt, &resolvedAt
+		updated, err := repo.Update(ctx, done, domain.ComplaintReceived)
+		require.NoError(t, err)
+		require.True(t, updated)
+		// Received after the quarter ended
+		testComplaint(t, db, repo, &userID, time.Date(1990, 4, 2, 9, 0, 0, 0, time.UTC))
+		// From someone without an account
+		walkIn := testComplaint(t, db, repo, nil, now)
+
+		lines, err := repo.ReturnLines(ctx, time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(1990, 4, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		byID := map[uuid.UUID]domain.ComplaintReturnLine{}
+		for _, line := range lines {
+			byID[line.ID] = line
+		}
+		assert.Len(t, byID, 4)
+		assert.Contains(t, byID, old.ID, "still open in the quarter")
+		assert.Contains(t, byID, late.ID)
+		assert.Contains(t, byID, fresh.ID)
+		assert.Equal(t, "individual", byID[late.ID].UserType)
+		assert.Empty(t, byID[walkIn.ID].UserType)
+	})
+
+	t.Run("one return per period", func(t *testing.T) {
+		t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.complaint_returns WHERE period = '1990-Q1'`) })
+		ret := &domain.ComplaintReturn{
+			Period: "1990-Q1", PeriodStart: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			PeriodEnd: time.Date(1990, 4, 1, 0, 0, 0, 0, time.UTC), Complaints: 4, Content: []byte("first"), GeneratedAt: now,
+		}
+		saved, err := repo.SaveReturn(ctx, ret)
+		require.NoError(t, err)
+		assert.True(t, saved)
+
+		again := *ret
+		again.Content = []byte("second")
+		saved, err = repo.SaveReturn(ctx, &again)
+		require.NoError(t, err)
+		assert.False(t, saved)
+
+		got, err := repo.GetReturn(ctx, "1990-Q1")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("first"), got.Content, "the first one filed is kept")
+
+		got, err = repo.GetReturn(ctx, "1990-Q2")
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+// testComplaint registers a complaint received at receivedAt, from userID
+// or, when nil, from a walk-in complainant. It is removed after the test.
+func testComplaint(t *testing.T, db *sqlx.DB, repo *ComplaintRepository, userID *uuid.UUID, receivedAt time.Time) *domain.Complaint {
+	t.Helper()
+	c := &domain.Complaint{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Channel:       domain.ComplaintChannelApp,
+		Category:      domain.ComplaintOther,
+		Language:      complaint.LanguageEnglish,
+		Subject:       "s",
+		Description:   "d",
+		Status:        domain.ComplaintReceived,
+		ReceivedAt:    receivedAt,
+		AcknowledgeBy: receivedAt.Add(48 * time.Hour),
+		ResolveBy:     receivedAt.Add(30 * 24 * time.Hour),
+		UpdatedAt:     receivedAt,
+	}
+	c.Reference = "TEST-" + c.ID.String()[:26]
+	if userID == nil {
+		c.ComplainantName = "Grace Phiri"
+	}
+	require.NoError(t, repo.Create(context.Background(), c))
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.complaints WHERE id = $1`, c.ID) })
+	return c
+}
+
+func complaintIDs(items []domain.Complaint) []uuid.UUID {
+	ids := make([]uuid.UUID, len(items))
+	for i, c := range items {
+		ids[i] = c.ID
+	}
+	return ids
+}
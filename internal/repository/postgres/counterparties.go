@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"kyd/internal/counterparty"
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type CounterpartyRepository struct {
+	db *sqlx.DB
+}
+
+func NewCounterpartyRepository(db *sqlx.DB) *CounterpartyRepository {
+	return &CounterpartyRepository{db: db}
+}
+
+const counterpartyColumns = `
+	id, code, name, type, country, status, status_reason, corridors,
+	exposure_limit, exposure_currency, contacts, notes, created_by, created_at, updated_at`
+
+const counterpartyDocumentColumns = `id, counterparty_id, kind, name, url, expires_at, uploaded_by, created_at`
+
+const prefundingColumns = `
+	id, counterparty_id, currency, kind, amount, balance_after, reference,
+	settlement_id, created_by, created_at`
+
+func (r *CounterpartyRepository) Create(ctx context.Context, c *domain.Counterparty) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.counterparties (`+counterpartyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`, c.ID, c.Code, c.Name, c.Type, c.Country, c.Status, c.StatusReason, c.Corridors,
+		c.ExposureLimit, c.ExposureCurrency, c.Contacts, c.Notes, c.CreatedBy, c.CreatedAt, c.UpdatedAt)
+	return counterpartyWriteError(err, "failed to create counterparty")
+}
+
+func (r *CounterpartyRepository) Update(ctx context.Context, c *domain.Counterparty) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.counterparties
+		SET code = $2, name = $3, type = $4, country = $5, status = $6, status_reason = $7,
+			corridors = $8, exposure_limit = $9, exposure_currency = $10, contacts = $11,
+			notes = $12, updated_at = $13
+		WHERE id = $1
+	`, c.ID, c.Code, c.Name, c.Type, c.Country, c.Status, c.StatusReason,
+		c.Corridors, c.ExposureLimit, c.ExposureCurrency, c.Contacts, c.Notes, c.UpdatedAt)
+	return counterpartyWriteError(err, "failed to update counterparty")
+}
+
+func counterpartyWriteError(err error, msg string) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return counterparty.ErrCounterpartyExists
+	}
+	return errors.Wrap(err, msg)
+}
+
+func (r *CounterpartyRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Counterparty, error) {
+	var c domain.Counterparty
+	err := r.db.GetContext(ctx, &c, `SELECT `+counterpartyColumns+` FROM admin_schema.counterparties WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, counterparty.ErrCounterpartyNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get counterparty")
+	}
+	return &c, nil
+}
+
+func (r *CounterpartyRepository) List(ctx context.Context, status, cpType string) ([]*domain.Counterparty, error) {
+	items := []*domain.Counterparty{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT `+counterpartyColumns+` FROM admin_schema.counterparties
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR type = $2)
+		ORDER BY code
+	`, status, cpType)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list counterparties")
+	}
+	return items, nil
+}
+
+func (r *CounterpartyRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	// Balances are only ever created by prefunding, which blocks deletion.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admin_schema.counterparty_balances WHERE counterparty_id = $1`, id); err != nil {
+		return false, errors.Wrap(err, "failed to delete counterparty balances")
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM admin_schema.counterparties WHERE id = $1`, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete counterparty")
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit transaction")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *CounterpartyRepository) AddDocument(ctx context.Context, d *domain.CounterpartyDocument) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.counterparty_documents (`+counterpartyDocumentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, d.ID, d.CounterpartyID, d.Kind, d.Name, d.URL, d.ExpiresAt, d.UploadedBy, d.CreatedAt)
+	return errors.Wrap(err, "failed to add counterparty document")
+}
+
+func (r *CounterpartyRepository) ListDocuments(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyDocument, error) {
+	docs := []*domain.CounterpartyDocument{}
+	err := r.db.SelectContext(ctx, &docs, `
+		SELECT `+counterpartyDocumentColumns+` FROM admin_schema.counterparty_documents
+		WHERE counterparty_id = $1 ORDER BY created_at DESC
+	`, counterpartyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list counterparty documents")
+	}
+	return docs, nil
+}
+
+func (r *CounterpartyRepository) DeleteDocument(ctx context.Context, counterpartyID, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.counterparty_documents WHERE id = $1 AND counterparty_id = $2`, id, counterpartyID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete counterparty document")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *CounterpartyRepository) Balances(ctx context.Context, counterpartyID uuid.UUID) ([]*domain.CounterpartyBalance, error) {
+	balances := []*domain.CounterpartyBalance{}
+	err := r.db.SelectContext(ctx, &balances, `
+		SELECT counterparty_id, currency, balance, updated_at FROM admin_schema.counterparty_balances
+		WHERE counterparty_id = $1 ORDER BY currency
+	`, counterpartyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list counterparty balances")
+	}
+	return balances, nil
+}
+
+func (r *CounterpartyRepository) ApplyPrefunding(ctx context.Context, e *domain.PrefundingEntry) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO admin_schema.counterparty_balances (counterparty_id, currency, balance, updated_at)
+		VALUES ($1, $2, 0, $3)
+		ON CONFLICT (counterparty_id, currency) DO NOTHING
+	`, e.CounterpartyID, e.Currency, e.CreatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to open counterparty balance")
+	}
+	// The row lock taken here makes two drawdowns racing for the last of
+	// the prefunding run one after the other; the second finds too little.
+	err = tx.GetContext(ctx, &e.BalanceAfter, `
+		UPDATE admin_schema.counterparty_balances
+		SET balance = balance + $3, updated_at = $4
+		WHERE counterparty_id = $1 AND currency = $2 AND balance + $3 >= 0
+		RETURNING balance
+	`, e.CounterpartyID, e.Currency, e.Amount, e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update counterparty balance")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO admin_schema.counterparty_prefunding (`+prefundingColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, e.ID, e.CounterpartyID, e.Currency, e.Kind, e.Amount, e.BalanceAfter, e.Reference,
+		e.SettlementID, e.CreatedBy, e.CreatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to record prefunding")
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit transaction")
+	}
+	return true, nil
+}
+
+func (r *CounterpartyRepository) ListPrefunding(ctx context.Context, counterpartyID uuid.UUID, limit, offset int) ([]*domain.PrefundingEntry, int, error) {
+	entries := []*domain.PrefundingEntry{}
+	err := r.db.SelectContext(ctx, &entries, `
+		SELECT `+prefundingColumns+` FROM admin_schema.counterparty_prefunding
+		WHERE counterparty_id = $1 ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`, counterpartyID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list prefunding")
+	}
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.counterparty_prefunding WHERE counterparty_id = $1`, counterpartyID); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count prefunding")
+	}
+	return entries, total, nil
+}
+
+func (r *CounterpartyRepository) Outstanding(ctx context.Context) ([]*domain.CounterpartyOutstanding, error) {
+	items := []*domain.CounterpartyOutstanding{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT (metadata->>'counterparty_id')::uuid AS counterparty_id, currency,
+			SUM(total_amount) AS amount, COUNT(*) AS settlements
+		FROM customer_schema.settlements
+		WHERE status IN ('pending', 'processing', 'submitted')
+			AND metadata @> '{"prefunding_drawn": true}'
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sum outstanding settlements")
+	}
+	return items, nil
+}
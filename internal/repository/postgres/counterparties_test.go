@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"kyd/internal/counterparty"
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterpartyRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewCounterpartyRepository(db)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	t.Run("codes are unique", func(t *testing.T) {
+		c := testCounterparty(t, db, repo, now)
+		dup := newTestCounterparty(now)
+		dup.Code = c.Code
+		assert.ErrorIs(t, repo.Create(ctx, dup), counterparty.ErrCounterpartyExists)
+
+		other := testCounterparty(t, db, repo, now)
+		other.Code = c.Code
+		assert.ErrorIs(t, repo.Update(ctx, other), counterparty.ErrCounterpartyExists)
+
+		_, err := repo.Get(ctx, uuid.New())
+		assert.ErrorIs(t, err, counterparty.ErrCounterpartyNotFound)
+	})
+
+	t.Run("prefunding never goes negative", func(t *testing.T) {
+		c := testCounterparty(t, db, repo, now)
+		ok, err := repo.ApplyPrefunding(ctx, prefunding(c, domain.PrefundingWithdrawal, -5, now))
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		deposit := prefunding(c, domain.PrefundingDeposit, 500, now)
+		ok, err = repo.ApplyPrefunding(ctx, deposit)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, deposit.BalanceAfter.Equal(decimal.NewFromInt(500)))
+
+		ok, err = repo.ApplyPrefunding(ctx, prefunding(c, domain.PrefundingDrawdown, -501, now))
+		require.NoError(t, err)
+		assert.False(t, ok)
+		drawdown := prefunding(c, domain.PrefundingDrawdown, -500, now.Add(time.Second))
+		ok, err = repo.ApplyPrefunding(ctx, drawdown)
+		require.NoError(t, err)
+		assert.True(t, ok, "down to exactly zero")
+		assert.True(t, drawdown.BalanceAfter.IsZero())
+
+		entries, total, err := repo.ListPrefunding(ctx, c.ID, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total, "refused movements are not recorded")
+		assert.Equal(t, drawdown.ID, entries[0].ID, "newest first")
+		balances, err := repo.Balances(ctx, c.ID)
+		require.NoError(t, err)
+		require.Len(t, balances, 1)
+		assert.True(t, balances[0].Balance.IsZero())
+	})
+
+	t.Run("racing drawdowns take the last of the prefunding once", func(t *testing.T) {
+		c := testCounterparty(t, db, repo, now)
+		_, err := repo.ApplyPrefunding(ctx, prefunding(c, domain.PrefundingDeposit, 100, now))
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		results := make([]bool, 5)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ok, err := repo.ApplyPrefunding(ctx, prefunding(c, domain.PrefundingDrawdown, -60, now))
+				assert.NoError(t, err)
+				results[i] = ok
+			}(i)
+		}
+		wg.Wait()
+		admitted := 0
+		for _, ok := range results {
+			if ok {
+				admitted++
+			}
+		}
+		assert.Equal(t, 1, admitted)
+	})
+
+	t.Run("outstanding counts undelivered drawn settlements", func(t *testing.T) {
+		c := testCounterparty(t, db, repo, now)
+		drawn := map[string]interface{}{"counterparty_id": c.ID.String(), "prefunding_drawn": true}
+		testSettlement(t, db, 100, "pending", drawn)
+		testSettlement(t, db, 50, "submitted", drawn)
+		testSettlement(t, db, 1000, "completed", drawn)
+		testSettlement(t, db, 1000, "pending", map[string]interface{}{"counterparty_id": c.ID.String()})
+
+		items, err := repo.Outstanding(ctx)
+		require.NoError(t, err)
+		var mine []*domain.CounterpartyOutstanding
+		for _, o := range items {
+			if o.CounterpartyID == c.ID {
+				mine = append(mine, o)
+			}
+		}
+		require.Len(t, mine, 1)
+		assert.True(t, mine[0].Amount.Equal(decimal.NewFromInt(150)))
+		assert.Equal(t, 2, mine[0].Settlements)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c := newTestCounterparty(now)
+		require.NoError(t, repo.Create(ctx, c))
+		deleted, err := repo.Delete(ctx, c.ID)
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		deleted, err = repo.Delete(ctx, c.ID)
+		require.NoError(t, err)
+		assert.False(t, deleted)
+	})
+}
+
+func newTestCounterparty(at time.Time) *domain.Counterparty {
+	id := uuid.New()
+	return &domain.Counterparty{
+		ID:        id,
+		Code:      "T-" + id.String()[:8],
+		Name:      "Test partner",
+		Type:      domain.CounterpartyPayoutProvider,
+		Country:   "ZA",
+		Status:    domain.CounterpartyActive,
+		Corridors: []string{"MWK-ZAR"},
+		Contacts:  domain.CounterpartyContacts{},
+		CreatedAt: at,
+		UpdatedAt: at,
+	}
+}
+
+// testCounterparty adds a counterparty and removes it, with its balances
+// and prefunding, after the test.
+func testCounterparty(t *testing.T, db *sqlx.DB, repo *CounterpartyRepository, at time.Time) *domain.Counterparty {
+	t.Helper()
+	c := newTestCounterparty(at)
+	require.NoError(t, repo.Create(context.Background(), c))
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM admin_schema.counterparty_prefunding WHERE counterparty_id = $1`, c.ID)
+		db.Exec(`DELETE FROM admin_schema.counterparty_balances WHERE counterparty_id = $1`, c.ID)
+		db.Exec(`DELETE FROM admin_schema.counterparties WHERE id = $1`, c.ID)
+	})
+	return c
+}
+
+func prefunding(c *domain.Counterparty, kind domain.PrefundingEntryKind, amount int64, at time.Time) *domain.PrefundingEntry {
+	return &domain.PrefundingEntry{
+		ID:             uuid.New(),
+		CounterpartyID: c.ID,
+		Currency:       domain.ZAR,
+		Kind:           kind,
+		Amount:         decimal.NewFromInt(amount),
+		Reference:      "TEST",
+		CreatedAt:      at,
+	}
+}
+
+// testSettlement adds a ZAR settlement in status and removes it after the
+// test.
+func testSettlement(t *testing.T, db *sqlx.DB, amount int64, status string, metadata map[string]interface{}) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO customer_schema.settlements (id, batch_reference, network, total_amount, currency, status, metadata)
+		VALUES ($1, $2, 'bank_transfer', $3, 'ZAR', $4, $5)
+	`, id, "TEST-"+id.String(), amount, status, domain.Metadata(metadata))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.settlements WHERE id = $1`, id) })
+	return id
+}
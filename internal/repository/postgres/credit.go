@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"kyd/internal/credit"
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const creditFacilityColumns = `
+	f.id, f.user_id, f.wallet_id, f.currency, f.credit_limit, f.annual_rate, f.status,
+	f.delinquency, f.overdrawn_since, f.accrued_interest, f.interest_collected,
+	f.accrued_through, f.opened_by, f.opened_at, f.closed_at, f.updated_at,
+	w.available_balance AS balance
+`
+
+// creditOwed matches facilities whose customer still owes anything.
+const creditOwed = `(w.available_balance < 0 OR f.accrued_interest > 0)`
+
+// CreditRepository stores overdraft facilities and keeps their wallets'
+// overdraft limits in step with them.
+type CreditRepository struct {
+	db *sqlx.DB
+}
+
+func NewCreditRepository(db *sqlx.DB) *CreditRepository {
+	return &CreditRepository{db: db}
+}
+
+func (r *CreditRepository) CreateFacility(ctx context.Context, f *domain.CreditFacility) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.credit_facilities (
+			id, user_id, wallet_id, currency, credit_limit, annual_rate, status,
+			delinquency, overdrawn_since, accrued_interest, interest_collected,
+			accrued_through, opened_by, opened_at, closed_at, updated_at
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+	`, f.ID, f.UserID, f.WalletID, f.Currency, f.Limit, f.AnnualRate, f.Status,
+		f.Delinquency, f.OverdrawnSince, f.AccruedInterest, f.InterestCollected,
+		f.AccruedThrough, f.OpenedBy, f.OpenedAt, f.ClosedAt, f.UpdatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return credit.ErrFacilityExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to create credit facility")
+	}
+	if err := setOverdraftLimit(ctx, tx, f); err != nil {
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "transaction commit failed")
+}
+
+func (r *CreditRepository) GetFacility(ctx context.Context, id uuid.UUID) (*domain.CreditFacility, error) {
+	var f domain.CreditFacility
+	err := r.db.GetContext(ctx, &f, `
+		SELECT `+creditFacilityColumns+`
+		FROM customer_schema.credit_facilities f
+		JOIN customer_schema.wallets w ON w.id = f.wallet_id
+		WHERE f.id = $1
+	`, id)
+	if err == sql.ErrNoRows {
+		return nil, credit.ErrFacilityNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get credit facility")
+	}
+	return &f, nil
+}
+
+func (r *CreditRepository) ListFacilities(ctx context.Context, filter domain.CreditFacilityFilter, limit, offset int) ([]domain.CreditFacility, int, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where = append(where, fmt.Sprintf("f.user_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		where = append(where, fmt.Sprintf("f.status = $%d", len(args)))
+	}
+	if filter.Delinquency != "" {
+		args = append(args, filter.Delinquency)
+		where = append(where, fmt.Sprintf("f.delinquency = $%d", len(args)))
+	}
+	from := ` FROM customer_schema.credit_facilities f JOIN customer_schema.wallets w ON w.id = f.wallet_id`
+	if len(where) > 0 {
+		from += ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*)`+from, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count credit facilities")
+	}
+	items := []domain.CreditFacility{}
+	query := `SELECT ` + creditFacilityColumns + from +
+		fmt.Sprintf(` ORDER BY f.opened_at DESC LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	if err := r.db.SelectContext(ctx, &items, query, append(args, limit, offset)...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list credit facilities")
+	}
+	return items, total, nil
+}
+
+func (r *CreditRepository) FacilitiesToReview(ctx context.Context, limit int) ([]domain.CreditFacility, error) {
+	items := []domain.CreditFacility{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT `+creditFacilityColumns+`
+		FROM customer_schema.credit_facilities f
+		JOIN customer_schema.wallets w ON w.id = f.wallet_id
+		WHERE f.status <> $1 OR `+creditOwed+`
+		ORDER BY f.accrued_through, f.opened_at
+		LIMIT $2
+	`, domain.CreditFacilityClosed, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find credit facilities to review")
+	}
+	return items, nil
+}
+
+func (r *CreditRepository) UpdateFacility(ctx context.Context, f *domain.CreditFacility) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.credit_facilities
+		SET credit_limit = $2, annual_rate = $3, status = $4, delinquency = $5,
+			overdrawn_since = $6, accrued_through = GREATEST(accrued_through, $7::date),
+			closed_at = $8, updated_at = $9
+		WHERE id = $1
+	`, f.ID, f.Limit, f.AnnualRate, f.Status, f.Delinquency,
+		f.OverdrawnSince, f.AccruedThrough, f.ClosedAt, f.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to update credit facility")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return credit.ErrFacilityNotFound
+	}
+	if err := setOverdraftLimit(ctx, tx, f); err != nil {
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "transaction commit failed")
+}
+
+// setOverdraftLimit lets an active facility's wallet overdraw up to the
+// limit. Any other wallet keeps a limit of what it is overdrawn already, so
+// it can only be repaid, and the limit falls as it is.
+func setOverdraftLimit(ctx context.Context, tx *sqlx.Tx, f *domain.CreditFacility) error {
+	limit := f.Limit
+	if f.Status != domain.CreditFacilityActive {
+		limit = decimal.Zero
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.wallets
+		SET overdraft_limit = GREATEST($2::numeric, -available_balance, -ledger_balance, 0), updated_at = NOW()
+		WHERE id = $1
+	`, f.WalletID, limit)
+	return errors.Wrap(err, "failed to set wallet overdraft limit")
+}
+
+func (r *CreditRepository) DelinquencyReport(ctx context.Context) ([]domain.CreditDelinquencyLine, error) {
+	lines := []domain.CreditDelinquencyLine{}
+	err := r.db.SelectContext(ctx, &lines, `
+		SELECT f.delinquency, f.currency, COUNT(*) AS facilities,
+			COALESCE(SUM(GREATEST(-w.available_balance, 0)), 0) AS overdrawn,
+			COALESCE(SUM(f.accrued_interest), 0) AS accrued_interest
+		FROM customer_schema.credit_facilities f
+		JOIN customer_schema.wallets w ON w.id = f.wallet_id
+		WHERE f.status <> $1 OR `+creditOwed+`
+		GROUP BY f.delinquency, f.currency
+		ORDER BY f.delinquency, f.currency
+	`, domain.CreditFacilityClosed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build credit delinquency report")
+	}
+	return lines, nil
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/credit"
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreditRepository_Facilities(t *testing.T) {
+	db := testDB(t)
+	repo := NewCreditRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := testUser(t, db, now)
+	wallet := testWallet(t, db, user, "MWK", domain.WalletStatusActive)
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.credit_facilities WHERE user_id = $1`, user) })
+	overdraftLimit := func() string {
+		var limit decimal.Decimal
+		require.NoError(t, db.Get(&limit, `SELECT overdraft_limit FROM customer_schema.wallets WHERE id = $1`, wallet))
+		return limit.String()
+	}
+	open := func() *domain.CreditFacility {
+		return &domain.CreditFacility{
+			ID: uuid.New(), UserID: user, WalletID: wallet, Currency: "MWK",
+			Limit: decimal.NewFromInt(1000), AnnualRate: decimal.RequireFromString("0.2"),
+			Status: domain.CreditFacilityActive, Delinquency: domain.CreditCurrent,
+			AccruedThrough: now, OpenedAt: now, UpdatedAt: now,
+		}
+	}
+
+	f := open()
+	require.NoError(t, repo.CreateFacility(ctx, f))
+	assert.Equal(t, "1000", overdraftLimit())
+	assert.ErrorIs(t, repo.CreateFacility(ctx, open()), credit.ErrFacilityExists, "one open facility per wallet")
+
+	// Accrual dates never go backwards.
+	f.AccruedThrough = now.AddDate(0, 0, -5)
+	require.NoError(t, repo.UpdateFacility(ctx, f))
+	got, err := repo.GetFacility(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, now.Format(time.DateOnly), got.AccruedThrough.Format(time.DateOnly))
+
+	// Overdraw, then suspend: the limit falls to what is owed.
+	_, err = db.Exec(`UPDATE customer_schema.wallets SET available_balance = -300, ledger_balance = -300 WHERE id = $1`, wallet)
+	require.NoError(t, err)
+	f.Status = domain.CreditFacilitySuspended
+	require.NoError(t, repo.UpdateFacility(ctx, f))
+	assert.Equal(t, "300", overdraftLimit())
+
+	// Still owed, so reviewed after closing.
+	f.Status, f.ClosedAt = domain.CreditFacilityClosed, &now
+	require.NoError(t, repo.UpdateFacility(ctx, f))
+	got, err = repo.GetFacility(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "-300", got.Balance.String())
+	assert.True(t, got.Owed())
+	review, err := repo.FacilitiesToReview(ctx, 100000)
+	require.NoError(t, err)
+	assert.Contains(t, facilityIDs(review), f.ID)
+
+	// Repaid, it is no longer reviewed, and the wallet may have another.
+	_, err = db.Exec(`UPDATE customer_schema.wallets SET available_balance = 0, ledger_balance = 0 WHERE id = $1`, wallet)
+	require.NoError(t, err)
+	review, err = repo.FacilitiesToReview(ctx, 100000)
+	require.NoError(t, err)
+	assert.NotContains(t, facilityIDs(review), f.ID)
+	require.NoError(t, repo.CreateFacility(ctx, open()))
+
+	_, err = repo.GetFacility(ctx, uuid.New())
+	assert.ErrorIs(t, err, credit.ErrFacilityNotFound)
+}
+
+func facilityIDs(facilities []domain.CreditFacility) []uuid.UUID {
+	ids := make([]uuid.UUID, len(facilities))
+	for i, f := range facilities {
+		ids[i] = f.ID
+	}
+	return ids
+}
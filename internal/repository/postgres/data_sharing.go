@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kyd/internal/datasharing"
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const dataPartnerColumns = `id, name, description, key_prefix, key_hash, active, created_by, created_at, updated_at`
+
+const dataSharingConsentColumns = `
+	c.id, c.user_id, c.partner_id, p.name AS partner_name, c.scopes, c.lookback_months,
+	c.partner_reference, c.granted_at, c.expires_at, c.revoked_at
+`
+
+// DataSharingRepository stores credit-scoring partners, the consents users
+// grant them and the log of their reads.
+type DataSharingRepository struct {
+	db *sqlx.DB
+}
+
+func NewDataSharingRepository(db *sqlx.DB) *DataSharingRepository {
+	return &DataSharingRepository{db: db}
+}
+
+func (r *DataSharingRepository) CreatePartner(ctx context.Context, p *domain.DataPartner) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.data_partners (`+dataPartnerColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+	`, p.ID, p.Name, p.Description, p.KeyPrefix, p.KeyHash, p.Active, p.CreatedBy, p.CreatedAt, p.UpdatedAt)
+	return errors.Wrap(err, "failed to create data partner")
+}
+
+func (r *DataSharingRepository) GetPartner(ctx context.Context, id uuid.UUID) (*domain.DataPartner, error) {
+	var p domain.DataPartner
+	err := r.db.GetContext(ctx, &p, `SELECT `+dataPartnerColumns+` FROM admin_schema.data_partners WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, datasharing.ErrPartnerNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get data partner")
+	}
+	return &p, nil
+}
+
+func (r *DataSharingRepository) PartnerByKeyHash(ctx context.Context, hash string) (*domain.DataPartner, error) {
+	var p domain.DataPartner
+	err := r.db.GetContext(ctx, &p, `SELECT `+dataPartnerColumns+` FROM admin_schema.data_partners WHERE key_hash = $1`, hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get data partner by key")
+	}
+	return &p, nil
+}
+
+func (r *DataSharingRepository) ListPartners(ctx context.Context, activeOnly bool) ([]domain.DataPartner, error) {
+	items := []domain.DataPartner{}
+	query := `SELECT ` + dataPartnerColumns + ` FROM admin_schema.data_partners`
+	if activeOnly {
+		query += ` WHERE active`
+	}
+	query += ` ORDER BY name`
+	if err := r.db.SelectContext(ctx, &items, query); err != nil {
+		return nil, errors.Wrap(err, "failed to list data partners")
+	}
+	return items, nil
+}
+
+func (r *DataSharingRepository) SetPartnerActive(ctx context.Context, id uuid.UUID, active bool, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.data_partners SET active = $2, updated_at = $3 WHERE id = $1
+	`, id, active, at)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update data partner")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update data partner")
+	}
+	return n > 0, nil
+}
+
+func (r *DataSharingRepository) CreateConsent(ctx context.Context, c *domain.DataSharingConsent) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.data_sharing_consents
+			(id, user_id, partner_id, scopes, lookback_months, partner_reference, granted_at, expires_at, revoked_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+	`, c.ID, c.UserID, c.PartnerID, pq.Array([]string(c.Scopes)), c.LookbackMonths, c.PartnerReference,
+		c.GrantedAt, c.ExpiresAt, c.RevokedAt)
+	return errors.Wrap(err, "failed to create data sharing consent")
+}
+
+func (r *DataSharingRepository) GetConsent(ctx context.Context, id uuid.UUID) (*domain.DataSharingConsent, error) {
+	var c domain.DataSharingConsent
+	err := r.db.GetContext(ctx, &c, `
+		SELECT `+dataSharingConsentColumns+`
+		FROM customer_schema.data_sharing_consents c
+		JOIN admin_schema.data_partners p ON p.id = c.partner_id
+		WHERE c.id = $1
+	`, id)
+	if err == sql.ErrNoRows {
+		return nil, datasharing.ErrConsentNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get data sharing consent")
+	}
+	return &c, nil
+}
+
+func (r *DataSharingRepository) ListConsents(ctx context.Context, userID uuid.UUID) ([]domain.DataSharingConsent, error) {
+	items := []domain.DataSharingConsent{}
+	query := `
+		SELECT ` + dataSharingConsentColumns + `
+		FROM customer_schema.data_sharing_consents c
+		JOIN admin_schema.data_partners p ON p.id = c.partner_id
+		WHERE c.user_id = $1
+		ORDER BY c.granted_at DESC
+	`
+	if err := r.db.SelectContext(ctx, &items, query, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to list data sharing consents")
+	}
+	return items, nil
+}
+
+func (r *DataSharingRepository) ListPartnerConsents(ctx context.Context, partnerID uuid.UUID, reference string, now time.Time) ([]domain.DataSharingConsent, error) {
+	items := []domain.DataSharingConsent{}
+	query := `
+		SELECT ` + dataSharingConsentColumns + `
+		FROM customer_schema.data_sharing_consents c
+		JOIN admin_schema.data_partners p ON p.id = c.partner_id
+		WHERE c.partner_id = $1 AND c.revoked_at IS NULL AND c.expires_at > $2
+		  AND ($3 = '' OR c.partner_reference = $3)
+		ORDER BY c.granted_at DESC
+	`
+	if err := r.db.SelectContext(ctx, &items, query, partnerID, now, reference); err != nil {
+		return nil, errors.Wrap(err, "failed to list partner consents")
+	}
+	return items, nil
+}
+
+func (r *DataSharingRepository) RevokeConsent(ctx context.Context, userID, id uuid.UUID, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.data_sharing_consents
+		SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > $3
+	`, id, userID, at)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke data sharing consent")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke data sharing consent")
+	}
+	return n > 0, nil
+}
+
+func (r *DataSharingRepository) LogAccess(ctx context.Context, a *domain.DataSharingAccess) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.data_sharing_access_log
+			(id, consent_id, user_id, partner_id, scopes, ip_address, accessed_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+	`, a.ID, a.ConsentID, a.UserID, a.PartnerID, pq.Array([]string(a.Scopes)), a.IPAddress, a.AccessedAt)
+	return errors.Wrap(err, "failed to log data sharing access")
+}
+
+func (r *DataSharingRepository) ListAccess(ctx context.Context, userID uuid.UUID, consentID *uuid.UUID, limit, offset int) ([]domain.DataSharingAccess, int, error) {
+	where := "WHERE a.user_id = $1"
+	args := []interface{}{userID}
+	if consentID != nil {
+		where += " AND a.consent_id = $2"
+		args = append(args, *consentID)
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.data_sharing_access_log a `+where, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count data sharing access")
+	}
+
+	items := []domain.DataSharingAccess{}
+	query := fmt.Sprintf(`
+		SELECT a.id, a.consent_id, a.user_id, a.partner_id, p.name AS partner_name, a.scopes, a.ip_address, a.accessed_at
+		FROM customer_schema.data_sharing_access_log a
+		JOIN admin_schema.data_partners p ON p.id = a.partner_id
+		%s
+		ORDER BY a.accessed_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	if err := r.db.SelectContext(ctx, &items, query, append(args, limit, offset)...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list data sharing access")
+	}
+	return items, total, nil
+}
+
+// MonthlyFlows counts money received from other users as income, adding
+// deposits for inflow, in the currency it was credited in. Money sent to
+// others and withdrawals are outflow in the currency debited.
+func (r *DataSharingRepository) MonthlyFlows(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]domain.MonthlyFlow, error) {
+	items := []domain.MonthlyFlow{}
+	query := `
+		WITH flows AS (
+			SELECT completed_at, converted_currency AS currency, converted_amount AS amount,
+			       TRUE AS inflow, transaction_type <> 'deposit' AS income
+			FROM customer_schema.transactions
+			WHERE receiver_id = $1 AND status = 'completed'
+			  AND completed_at >= $2 AND completed_at < $3
+			  AND (transaction_type = 'deposit'
+			       OR (sender_id <> $1 AND transaction_type IN ('payment', 'transfer', 'settlement')))
+			UNION ALL
+			SELECT completed_at, currency, amount, FALSE, FALSE
+			FROM customer_schema.transactions
+			WHERE sender_id = $1 AND status = 'completed'
+			  AND completed_at >= $2 AND completed_at < $3
+			  AND (transaction_type = 'withdrawal'
+			       OR (receiver_id <> $1 AND transaction_type IN ('payment', 'transfer')))
+		)
+		SELECT date_trunc('month', completed_at AT TIME ZONE 'UTC') AS month, currency,
+		       COUNT(*) FILTER (WHERE income) AS income_count,
+		       COALESCE(SUM(amount) FILTER (WHERE income), 0) AS income_amount,
+		       COUNT(*) FILTER (WHERE inflow) AS inflow_count,
+		       COALESCE(SUM(amount) FILTER (WHERE inflow), 0) AS inflow_amount,
+		       COUNT(*) FILTER (WHERE NOT inflow) AS outflow_count,
+		       COALESCE(SUM(amount) FILTER (WHERE NOT inflow), 0) AS outflow_amount
+		FROM flows
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`
+	if err := r.db.SelectContext(ctx, &items, query, userID, from, to); err != nil {
+		return nil, errors.Wrap(err, "failed to sum monthly flows")
+	}
+	return items, nil
+}
@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// apiKeyErrorSamples is how many failed calls are kept per key.
+const apiKeyErrorSamples = 50
+
+// DeveloperRepository keeps API key usage, failed-call samples and the
+// environments accounts have switched on, for the developer portal.
+type DeveloperRepository struct {
+	db *sqlx.DB
+}
+
+func NewDeveloperRepository(db *sqlx.DB) *DeveloperRepository {
+	return &DeveloperRepository{db: db}
+}
+
+// RecordAPIKeyCall counts call against its key's day and, when it failed,
+// keeps it as a sample in place of the key's oldest one.
+func (r *DeveloperRepository) RecordAPIKeyCall(ctx context.Context, call domain.APIKeyCall) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	clientError, serverError := 0, 0
+	switch {
+	case call.Status >= 500:
+		serverError = 1
+	case call.Status >= 400:
+		clientError = 1
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO admin_schema.api_key_usage (api_key_id, day, requests, client_errors, server_errors, total_latency_ms)
+		VALUES ($1, $2, 1, $3, $4, $5)
+		ON CONFLICT (api_key_id, day) DO UPDATE SET
+			requests = api_key_usage.requests + 1,
+			client_errors = api_key_usage.client_errors + EXCLUDED.client_errors,
+			server_errors = api_key_usage.server_errors + EXCLUDED.server_errors,
+			total_latency_ms = api_key_usage.total_latency_ms + EXCLUDED.total_latency_ms
+	`, call.KeyID, call.At.UTC().Format("2006-01-02"), clientError, serverError, call.Latency.Milliseconds())
+	if err != nil {
+		return errors.Wrap(err, "failed to record api key usage")
+	}
+
+	if call.Failed() {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO admin_schema.api_key_errors (id, api_key_id, method, path, status, request_id, response_body, occurred_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, uuid.New(), call.KeyID, call.Method, call.Path, call.Status, call.RequestID, call.ResponseBody, call.At)
+		if err != nil {
+			return errors.Wrap(err, "failed to record api key error")
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM admin_schema.api_key_errors
+			WHERE api_key_id = $1 AND id NOT IN (
+				SELECT id FROM admin_schema.api_key_errors
+				WHERE api_key_id = $1
+				ORDER BY occurred_at DESC
+				LIMIT $2
+			)
+		`, call.KeyID, apiKeyErrorSamples)
+		if err != nil {
+			return errors.Wrap(err, "failed to prune api key errors")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit api key usage")
+}
+
+// APIKeyUsage returns a key's days from since on, oldest first. Days
+// without calls are left out.
+func (r *DeveloperRepository) APIKeyUsage(ctx context.Context, keyID uuid.UUID, since time.Time) ([]domain.APIKeyUsageDay, error) {
+	days := []domain.APIKeyUsageDay{}
+	err := r.db.SelectContext(ctx, &days, `
+		SELECT day, requests, client_errors, server_errors, total_latency_ms
+		FROM admin_schema.api_key_usage
+		WHERE api_key_id = $1 AND day >= $2
+		ORDER BY day
+	`, keyID, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load api key usage")
+	}
+	return days, nil
+}
+
+// APIKeyUsageTotals sums the usage of each of owner's keys from since on.
+func (r *DeveloperRepository) APIKeyUsageTotals(ctx context.Context, owner uuid.UUID, since time.Time) (map[uuid.UUID]domain.APIKeyUsageDay, error) {
+	var rows []struct {
+		KeyID uuid.UUID `db:"api_key_id"`
+		domain.APIKeyUsageDay
+	}
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT u.api_key_id, MIN(u.day) AS day, SUM(u.requests) AS requests, SUM(u.client_errors) AS client_errors,
+			SUM(u.server_errors) AS server_errors, SUM(u.total_latency_ms) AS total_latency_ms
+		FROM admin_schema.api_key_usage u
+		JOIN admin_schema.api_keys k ON k.id = u.api_key_id
+		WHERE k.owner_id = $1 AND u.day >= $2
+		GROUP BY u.api_key_id
+	`, owner, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to total api key usage")
+	}
+	totals := make(map[uuid.UUID]domain.APIKeyUsageDay, len(rows))
+	for _, row := range rows {
+		totals[row.KeyID] = row.APIKeyUsageDay
+	}
+	return totals, nil
+}
+
+// ListAPIKeyErrors returns a key's latest failed calls, newest first.
+func (r *DeveloperRepository) ListAPIKeyErrors(ctx context.Context, keyID uuid.UUID, limit int) ([]domain.APIKeyError, error) {
+	samples := []domain.APIKeyError{}
+	err := r.db.SelectContext(ctx, &samples, `
+		SELECT id, api_key_id, method, path, status, request_id, response_body, occurred_at
+		FROM admin_schema.api_key_errors
+		WHERE api_key_id = $1
+		ORDER BY occurred_at DESC
+		LIMIT $2
+	`, keyID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list api key errors")
+	}
+	return samples, nil
+}
+
+// GetEnvironments returns the environments owner switched on; both are on
+// until the owner changes them.
+func (r *DeveloperRepository) GetEnvironments(ctx context.Context, owner uuid.UUID) (*domain.DeveloperEnvironments, error) {
+	var env domain.DeveloperEnvironments
+	err := r.db.GetContext(ctx, &env, `
+		SELECT production_enabled, sandbox_enabled, updated_at
+		FROM customer_schema.developer_environments
+		WHERE user_id = $1
+	`, owner)
+	if err == sql.ErrNoRows {
+		return &domain.DeveloperEnvironments{Production: true, Sandbox: true}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load developer environments")
+	}
+	return &env, nil
+}
+
+func (r *DeveloperRepository) SetEnvironments(ctx context.Context, owner uuid.UUID, env *domain.DeveloperEnvironments) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.developer_environments (user_id, production_enabled, sandbox_enabled, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			production_enabled = EXCLUDED.production_enabled,
+			sandbox_enabled = EXCLUDED.sandbox_enabled,
+			updated_at = EXCLUDED.updated_at
+	`, owner, env.Production, env.Sandbox, env.UpdatedAt)
+	return errors.Wrap(err, "failed to save developer environments")
+}
@@ -0,0 +1,254 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// DormancyRepository keeps the dormant account workflow: who is inactive,
+// whose wallets are frozen and what was escheated.
+type DormancyRepository struct {
+	db *sqlx.DB
+}
+
+func NewDormancyRepository(db *sqlx.DB) *DormancyRepository {
+	return &DormancyRepository{db: db}
+}
+
+const dormantAccountColumns = `
+	user_id, country_code, status, last_activity_at, notices_sent, last_notice_at,
+	dormant_at, escheatment_notice_at, escheated_at, frozen_wallets,
+	reactivated_at, reactivated_by, created_at, updated_at`
+
+const escheatmentColumns = `
+	id, user_id, wallet_id, country_code, amount, currency, status,
+	transaction_id, return_transaction_id, escheated_at, returned_at`
+
+// FindInactive returns customers last active at or before cutoff, oldest
+// first.
+// Activity is the latest of a login, a wallet transaction, registration and
+// reactivation. Admins and the platform's house users never go dormant,
+// and accounts already dormant or escheated are left to the next stages.
+func (r *DormancyRepository) FindInactive(ctx context.Context, cutoff time.Time, limit int) ([]domain.DormancyCandidate, error) {
+	candidates := []domain.DormancyCandidate{}
+	err := r.db.SelectContext(ctx, &candidates, `
+		SELECT user_id, country_code, last_activity_at
+		FROM (
+			SELECT u.id AS user_id, u.country_code,
+				GREATEST(
+					u.created_at,
+					u.last_login,
+					(SELECT MAX(w.last_transaction_at) FROM customer_schema.wallets w WHERE w.user_id = u.id),
+					d.reactivated_at
+				) AS last_activity_at
+			FROM customer_schema.users u
+			LEFT JOIN customer_schema.dormant_accounts d ON d.user_id = u.id
+			WHERE u.user_type <> $1 AND u.user_status <> 'deleted'
+				AND NOT EXISTS (SELECT 1 FROM customer_schema.ledger_house_users h WHERE h.user_id = u.id)
+				AND (d.status IS NULL OR d.status NOT IN ($2, $3, $4))
+		) activity
+		WHERE last_activity_at <= $5
+		ORDER BY last_activity_at
+		LIMIT $6
+	`, domain.UserTypeAdmin, domain.DormancyDormant, domain.DormancyEscheated, domain.DormancyReactivationRequested, cutoff, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find inactive customers")
+	}
+	return candidates, nil
+}
+
+// GetAccount returns nil when the customer never entered the workflow.
+func (r *DormancyRepository) GetAccount(ctx context.Context, userID uuid.UUID) (*domain.DormantAccount, error) {
+	var a domain.DormantAccount
+	err := r.db.GetContext(ctx, &a, `SELECT`+dormantAccountColumns+` FROM customer_schema.dormant_accounts WHERE user_id = $1`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get dormant account")
+	}
+	return &a, nil
+}
+
+func (r *DormancyRepository) SaveAccount(ctx context.Context, a *domain.DormantAccount) error {
+	if err := saveDormantAccount(ctx, r.db, a); err != nil {
+		return errors.Wrap(err, "failed to save dormant account")
+	}
+	return nil
+}
+
+func saveDormantAccount(ctx context.Context, db sqlx.ExtContext, a *domain.DormantAccount) error {
+	if a.FrozenWallets == nil {
+		a.FrozenWallets = pq.StringArray{}
+	}
+	_, err := sqlx.NamedExecContext(ctx, db, `
+		INSERT INTO customer_schema.dormant_accounts (`+dormantAccountColumns+`)
+		VALUES (
+			:user_id, :country_code, :status, :last_activity_at, :notices_sent, :last_notice_at,
+			:dormant_at, :escheatment_notice_at, :escheated_at, :frozen_wallets,
+			:reactivated_at, :reactivated_by, :created_at, :updated_at
+		)
+		ON CONFLICT (user_id) DO UPDATE SET
+			country_code = EXCLUDED.country_code,
+			status = EXCLUDED.status,
+			last_activity_at = EXCLUDED.last_activity_at,
+			notices_sent = EXCLUDED.notices_sent,
+			last_notice_at = EXCLUDED.last_notice_at,
+			dormant_at = EXCLUDED.dormant_at,
+			escheatment_notice_at = EXCLUDED.escheatment_notice_at,
+			escheated_at = EXCLUDED.escheated_at,
+			frozen_wallets = EXCLUDED.frozen_wallets,
+			reactivated_at = EXCLUDED.reactivated_at,
+			reactivated_by = EXCLUDED.reactivated_by,
+			updated_at = EXCLUDED.updated_at
+	`, a)
+	return err
+}
+
+// FindDormant returns dormant accounts last active at or before cutoff,
+// oldest first.
+func (r *DormancyRepository) FindDormant(ctx context.Context, cutoff time.Time, limit int) ([]*domain.DormantAccount, error) {
+	accounts := []*domain.DormantAccount{}
+	err := r.db.SelectContext(ctx, &accounts, `
+		SELECT`+dormantAccountColumns+`
+		FROM customer_schema.dormant_accounts
+		WHERE status = $1 AND last_activity_at <= $2
+		ORDER BY last_activity_at
+		LIMIT $3
+	`, domain.DormancyDormant, cutoff, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find dormant accounts")
+	}
+	return accounts, nil
+}
+
+// ListAccounts returns accounts in the workflow, most recently changed
+// first, optionally in one status.
+func (r *DormancyRepository) ListAccounts(ctx context.Context, status domain.DormancyStatus, limit, offset int) ([]domain.DormantAccount, int, error) {
+	where := `WHERE ($1 = '' OR status = $1)`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.dormant_accounts `+where, status); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count dormant accounts")
+	}
+	accounts := []domain.DormantAccount{}
+	err := r.db.SelectContext(ctx, &accounts, `
+		SELECT`+dormantAccountColumns+`
+		FROM customer_schema.dormant_accounts `+where+`
+		ORDER BY updated_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list dormant accounts")
+	}
+	return accounts, total, nil
+}
+
+// MarkDormant suspends the customer's active wallets, records them as
+// frozen and saves a, in one transaction.
+func (r *DormancyRepository) MarkDormant(ctx context.Context, a *domain.DormantAccount) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	frozen := pq.StringArray{}
+	err = tx.SelectContext(ctx, &frozen, `
+		UPDATE customer_schema.wallets
+		SET status = $2, updated_at = NOW()
+		WHERE user_id = $1 AND status = $3
+		RETURNING id::text
+	`, a.UserID, domain.WalletStatusSuspended, domain.WalletStatusActive)
+	if err != nil {
+		return errors.Wrap(err, "failed to freeze wallets")
+	}
+	a.FrozenWallets = frozen
+	if err := saveDormantAccount(ctx, tx, a); err != nil {
+		return errors.Wrap(err, "failed to save dormant account")
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit dormancy")
+	}
+	return nil
+}
+
+// MarkReactivated reopens the wallets frozen at dormancy and saves a, in
+// one transaction. Wallets that were suspended for another reason before
+// dormancy are left alone.
+func (r *DormancyRepository) MarkReactivated(ctx context.Context, a *domain.DormantAccount) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if len(a.FrozenWallets) > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE customer_schema.wallets
+			SET status = $3, updated_at = NOW()
+			WHERE user_id = $1 AND id::text = ANY($2) AND status = $4
+		`, a.UserID, a.FrozenWallets, domain.WalletStatusActive, domain.WalletStatusSuspended); err != nil {
+			return errors.Wrap(err, "failed to unfreeze wallets")
+		}
+	}
+	if err := saveDormantAccount(ctx, tx, a); err != nil {
+		return errors.Wrap(err, "failed to save dormant account")
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit reactivation")
+	}
+	return nil
+}
+
+// HeldEscheatments returns the customer's escheated balances not yet
+// returned.
+func (r *DormancyRepository) HeldEscheatments(ctx context.Context, userID uuid.UUID) ([]domain.Escheatment, error) {
+	held := []domain.Escheatment{}
+	err := r.db.SelectContext(ctx, &held, `
+		SELECT`+escheatmentColumns+`
+		FROM customer_schema.escheatments
+		WHERE user_id = $1 AND status = $2
+		ORDER BY escheated_at
+	`, userID, domain.EscheatmentHeld)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list held escheatments")
+	}
+	return held, nil
+}
+
+// EscheatmentReport returns the escheatments made in [from, to) with their
+// totals per country and currency.
+func (r *DormancyRepository) EscheatmentReport(ctx context.Context, from, to time.Time) (*domain.EscheatmentReport, error) {
+	report := &domain.EscheatmentReport{From: from, To: to, Totals: []domain.EscheatmentTotal{}, Items: []domain.Escheatment{}}
+	err := r.db.SelectContext(ctx, &report.Totals, `
+		SELECT country_code, currency, COUNT(*) AS count,
+			SUM(amount) AS escheated,
+			COALESCE(SUM(amount) FILTER (WHERE status = $3), 0) AS returned,
+			COALESCE(SUM(amount) FILTER (WHERE status = $4), 0) AS held
+		FROM customer_schema.escheatments
+		WHERE escheated_at >= $1 AND escheated_at < $2
+		GROUP BY country_code, currency
+		ORDER BY country_code, currency
+	`, from, to, domain.EscheatmentReturned, domain.EscheatmentHeld)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to total escheatments")
+	}
+	err = r.db.SelectContext(ctx, &report.Items, `
+		SELECT`+escheatmentColumns+`
+		FROM customer_schema.escheatments
+		WHERE escheated_at >= $1 AND escheated_at < $2
+		ORDER BY escheated_at
+	`, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list escheatments")
+	}
+	return report, nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDormancyRepository_Workflow(t *testing.T) {
+	db := testDB(t)
+	repo := NewDormancyRepository(db)
+	ctx := context.Background()
+
+	// Older than any seeded customer, so first in line.
+	lastActive := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	idle, recent := testUser(t, db, lastActive), testUser(t, db, now)
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.dormant_accounts WHERE user_id = $1`, idle) })
+	active := testWallet(t, db, idle, "MWK", domain.WalletStatusActive)
+	held := testWallet(t, db, idle, "USD", domain.WalletStatusSuspended)
+	cutoff := now.AddDate(-1, 0, 0)
+
+	inactive := func() []uuid.UUID {
+		candidates, err := repo.FindInactive(ctx, cutoff, 100000)
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, len(candidates))
+		for i, c := range candidates {
+			ids[i] = c.UserID
+		}
+		return ids
+	}
+	require.Contains(t, inactive(), idle)
+	assert.NotContains(t, inactive(), recent)
+
+	a := &domain.DormantAccount{
+		UserID: idle, CountryCode: "MW", Status: domain.DormancyDormant, LastActivityAt: lastActive,
+		DormantAt: &now, CreatedAt: now, UpdatedAt: now,
+	}
+	require.NoError(t, repo.MarkDormant(ctx, a))
+	assert.Equal(t, pq.StringArray{active.String()}, a.FrozenWallets, "only active wallets are frozen")
+	assert.Equal(t, domain.WalletStatusSuspended, walletStatus(t, db, active))
+	assert.NotContains(t, inactive(), idle, "dormant accounts are left to escheatment")
+
+	dormant, err := repo.FindDormant(ctx, cutoff, 100000)
+	require.NoError(t, err)
+	var found bool
+	for _, d := range dormant {
+		found = found || d.UserID == idle
+	}
+	assert.True(t, found)
+
+	a.Status, a.ReactivatedAt = domain.DormancyReactivated, &now
+	require.NoError(t, repo.MarkReactivated(ctx, a))
+	assert.Equal(t, domain.WalletStatusActive, walletStatus(t, db, active))
+	assert.Equal(t, domain.WalletStatusSuspended, walletStatus(t, db, held), "suspended before dormancy")
+	assert.NotContains(t, inactive(), idle, "reactivation counts as activity")
+
+	got, err := repo.GetAccount(ctx, idle)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DormancyReactivated, got.Status)
+	missing, err := repo.GetAccount(ctx, recent)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"kyd/internal/domain"
+	"kyd/internal/security"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// EligibilityRepository stores registration eligibility rules and the
+// registrations they refused. Applicants' emails are encrypted, with a
+// blind index to find approved appeals.
+type EligibilityRepository struct {
+	db     *sqlx.DB
+	crypto *security.CryptoService
+}
+
+func NewEligibilityRepository(db *sqlx.DB, crypto *security.CryptoService) *EligibilityRepository {
+	return &EligibilityRepository{db: db, crypto: crypto}
+}
+
+const eligibilityRuleColumns = `country_code, min_age, require_date_of_birth, restricted_nationalities,
+	restricted_user_types, restricted_business_types, updated_by, updated_at`
+
+func (r *EligibilityRepository) GetEligibilityRule(ctx context.Context, countryCode string) (*domain.EligibilityRule, error) {
+	var rule domain.EligibilityRule
+	err := r.db.GetContext(ctx, &rule, `SELECT `+eligibilityRuleColumns+` FROM admin_schema.eligibility_rules WHERE country_code = $1`, countryCode)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get eligibility rule")
+	}
+	return &rule, nil
+}
+
+func (r *EligibilityRepository) ListEligibilityRules(ctx context.Context) ([]domain.EligibilityRule, error) {
+	rules := []domain.EligibilityRule{}
+	if err := r.db.SelectContext(ctx, &rules, `SELECT `+eligibilityRuleColumns+` FROM admin_schema.eligibility_rules ORDER BY country_code`); err != nil {
+		return nil, errors.Wrap(err, "failed to list eligibility rules")
+	}
+	return rules, nil
+}
+
+func (r *EligibilityRepository) UpsertEligibilityRule(ctx context.Context, rule *domain.EligibilityRule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.eligibility_rules (`+eligibilityRuleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (country_code) DO UPDATE SET
+			min_age = EXCLUDED.min_age,
+			require_date_of_birth = EXCLUDED.require_date_of_birth,
+			restricted_nationalities = EXCLUDED.restricted_nationalities,
+			restricted_user_types = EXCLUDED.restricted_user_types,
+			restricted_business_types = EXCLUDED.restricted_business_types,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+	`, rule.CountryCode, rule.MinAge, rule.RequireDateOfBirth, rule.RestrictedNationalities,
+		rule.RestrictedUserTypes, rule.RestrictedBusinessTypes, rule.UpdatedBy, rule.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to save eligibility rule")
+	}
+	return nil
+}
+
+func (r *EligibilityRepository) DeleteEligibilityRule(ctx context.Context, countryCode string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.eligibility_rules WHERE country_code = $1`, countryCode); err != nil {
+		return errors.Wrap(err, "failed to delete eligibility rule")
+	}
+	return nil
+}
+
+const eligibilityRejectionColumns = `id, email, country_code, user_type, date_of_birth, nationality, business_type,
+	reasons, status, appeal_statement, appealed_at, reviewed_by, review_note, reviewed_at, created_at`
+
+func (r *EligibilityRepository) CreateEligibilityRejection(ctx context.Context, rej *domain.EligibilityRejection) error {
+	encEmail, err := r.crypto.Encrypt(rej.Email)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt email")
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.eligibility_rejections (
+			id, email, email_hash, country_code, user_type, date_of_birth, nationality, business_type,
+			reasons, status, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, rej.ID, encEmail, r.crypto.BlindIndex(rej.Email), rej.CountryCode, rej.UserType, rej.DateOfBirth,
+		rej.Nationality, rej.BusinessType, rej.Reasons, rej.Status, rej.CreatedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to record eligibility rejection")
+	}
+	return nil
+}
+
+func (r *EligibilityRepository) GetEligibilityRejection(ctx context.Context, id uuid.UUID) (*domain.EligibilityRejection, error) {
+	var rej domain.EligibilityRejection
+	err := r.db.GetContext(ctx, &rej, `SELECT `+eligibilityRejectionColumns+` FROM admin_schema.eligibility_rejections WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get eligibility rejection")
+	}
+	if err := r.decrypt(&rej); err != nil {
+		return nil, err
+	}
+	return &rej, nil
+}
+
+func (r *EligibilityRepository) ListEligibilityRejections(ctx context.Context, status domain.EligibilityRejectionStatus, limit, offset int) ([]domain.EligibilityRejection, int, error) {
+	var total int
+	if err := r.db.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM admin_schema.eligibility_rejections WHERE $1 = '' OR status = $1
+	`, status); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count eligibility rejections")
+	}
+	items := []domain.EligibilityRejection{}
+	if err := r.db.SelectContext(ctx, &items, `
+		SELECT `+eligibilityRejectionColumns+` FROM admin_schema.eligibility_rejections
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list eligibility rejections")
+	}
+	for i := range items {
+		if err := r.decrypt(&items[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return items, total, nil
+}
+
+func (r *EligibilityRepository) UpdateEligibilityRejection(ctx context.Context, rej *domain.EligibilityRejection) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.eligibility_rejections
+		SET status = $2, appeal_statement = $3, appealed_at = $4, reviewed_by = $5, review_note = $6, reviewed_at = $7
+		WHERE id = $1
+	`, rej.ID, rej.Status, rej.AppealStatement, rej.AppealedAt, rej.ReviewedBy, rej.ReviewNote, rej.ReviewedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to update eligibility rejection")
+	}
+	return nil
+}
+
+func (r *EligibilityRepository) HasApprovedAppeal(ctx context.Context, email, countryCode string) (bool, error) {
+	var approved bool
+	err := r.db.GetContext(ctx, &approved, `
+		SELECT EXISTS(
+			SELECT 1 FROM admin_schema.eligibility_rejections
+			WHERE email_hash = $1 AND country_code = $2 AND status = 'approved'
+		)
+	`, r.crypto.BlindIndex(email), countryCode)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check approved appeals")
+	}
+	return approved, nil
+}
+
+func (r *EligibilityRepository) decrypt(rej *domain.EligibilityRejection) error {
+	email, err := r.crypto.Decrypt(rej.Email)
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt email")
+	}
+	rej.Email = email
+	return nil
+}
@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/security"
+	"kyd/pkg/errors"
+	"kyd/pkg/mailer"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// EmailDeliveryRepository stores tracked email and the suppression list.
+// It implements mailer.Store.
+type EmailDeliveryRepository struct {
+	db     *sqlx.DB
+	crypto *security.CryptoService
+}
+
+func NewEmailDeliveryRepository(db *sqlx.DB, crypto *security.CryptoService) *EmailDeliveryRepository {
+	return &EmailDeliveryRepository{db: db, crypto: crypto}
+}
+
+const emailMessageColumns = `id, kind, critical, recipient, subject, body, status, attempts,
+	last_error, next_attempt_at, sent_at, resent_from, created_at, updated_at`
+
+type emailMessageRow struct {
+	ID            uuid.UUID      `db:"id"`
+	Kind          string         `db:"kind"`
+	Critical      bool           `db:"critical"`
+	Recipient     string         `db:"recipient"`
+	Subject       string         `db:"subject"`
+	Body          string         `db:"body"`
+	Status        string         `db:"status"`
+	Attempts      int            `db:"attempts"`
+	LastError     sql.NullString `db:"last_error"`
+	NextAttemptAt *time.Time     `db:"next_attempt_at"`
+	SentAt        *time.Time     `db:"sent_at"`
+	ResentFrom    *uuid.UUID     `db:"resent_from"`
+	CreatedAt     time.Time      `db:"created_at"`
+	UpdatedAt     time.Time      `db:"updated_at"`
+}
+
+func (r *EmailDeliveryRepository) toMessage(row *emailMessageRow) (*mailer.Message, error) {
+	to, err := r.crypto.Decrypt(row.Recipient)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt recipient")
+	}
+	body, err := r.crypto.Decrypt(row.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt body")
+	}
+	return &mailer.Message{
+		ID:            row.ID,
+		Kind:          row.Kind,
+		Critical:      row.Critical,
+		To:            to,
+		Subject:       row.Subject,
+		Body:          body,
+		Status:        mailer.DeliveryStatus(row.Status),
+		Attempts:      row.Attempts,
+		LastError:     row.LastError.String,
+		NextAttemptAt: row.NextAttemptAt,
+		SentAt:        row.SentAt,
+		ResentFrom:    row.ResentFrom,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}, nil
+}
+
+func (r *EmailDeliveryRepository) toMessages(rows []emailMessageRow) ([]*mailer.Message, error) {
+	out := make([]*mailer.Message, 0, len(rows))
+	for i := range rows {
+		m, err := r.toMessage(&rows[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// recipientIndex is the blind index of an address. Addresses are compared
+// case-insensitively.
+func (r *EmailDeliveryRepository) recipientIndex(email string) string {
+	return r.crypto.BlindIndex(strings.ToLower(strings.TrimSpace(email)))
+}
+
+func (r *EmailDeliveryRepository) Create(ctx context.Context, m *mailer.Message) error {
+	encTo, err := r.crypto.Encrypt(m.To)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt recipient")
+	}
+	encBody, err := r.crypto.Encrypt(m.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt body")
+	}
+	query := `
+		INSERT INTO customer_schema.email_messages (
+			id, kind, critical, recipient, recipient_index, subject, body, status,
+			attempts, last_error, next_attempt_at, sent_at, resent_from, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12, $13, $14, $15)
+	`
+	_, err = r.db.ExecContext(ctx, query,
+		m.ID, m.Kind, m.Critical, encTo, r.recipientIndex(m.To), m.Subject, encBody, string(m.Status),
+		m.Attempts, m.LastError, m.NextAttemptAt, m.SentAt, m.ResentFrom, m.CreatedAt, m.UpdatedAt)
+	return errors.Wrap(err, "failed to create email message")
+}
+
+func (r *EmailDeliveryRepository) Update(ctx context.Context, m *mailer.Message) error {
+	query := `
+		UPDATE customer_schema.email_messages
+		SET status = $2, attempts = $3, last_error = NULLIF($4, ''), next_attempt_at = $5,
+		    sent_at = $6, updated_at = $7
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, m.ID, string(m.Status), m.Attempts, m.LastError, m.NextAttemptAt, m.SentAt, m.UpdatedAt)
+	return errors.Wrap(err, "failed to update email message")
+}
+
+func (r *EmailDeliveryRepository) Get(ctx context.Context, id uuid.UUID) (*mailer.Message, error) {
+	var row emailMessageRow
+	query := `SELECT ` + emailMessageColumns + ` FROM customer_schema.email_messages WHERE id = $1`
+	if err := r.db.GetContext(ctx, &row, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, mailer.ErrMessageNotFound
+		}
+		return nil, errors.Wrap(err, "failed to get email message")
+	}
+	return r.toMessage(&row)
+}
+
+func (r *EmailDeliveryRepository) List(ctx context.Context, f mailer.MessageFilter) ([]*mailer.Message, int, error) {
+	var where []string
+	var args []interface{}
+	if f.Status != "" {
+		args = append(args, string(f.Status))
+		where = append(where, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if f.CriticalOnly {
+		where = append(where, "critical = TRUE")
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.email_messages`+cond, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count email messages")
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM customer_schema.email_messages%s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		emailMessageColumns, cond, len(args)-1, len(args))
+	var rows []emailMessageRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list email messages")
+	}
+	msgs, err := r.toMessages(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return msgs, total, nil
+}
+
+func (r *EmailDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*mailer.Message, error) {
+	query := `
+		UPDATE customer_schema.email_messages
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM customer_schema.email_messages
+			WHERE status = 'queued' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + emailMessageColumns
+	var rows []emailMessageRow
+	if err := r.db.SelectContext(ctx, &rows, query, now, now.Add(lease), limit); err != nil {
+		return nil, errors.Wrap(err, "failed to claim due email messages")
+	}
+	return r.toMessages(rows)
+}
+
+func (r *EmailDeliveryRepository) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM customer_schema.email_suppressions WHERE recipient_index = $1)`
+	if err := r.db.GetContext(ctx, &exists, query, r.recipientIndex(email)); err != nil {
+		return false, errors.Wrap(err, "failed to check email suppression")
+	}
+	return exists, nil
+}
+
+func (r *EmailDeliveryRepository) Suppress(ctx context.Context, email, reason string) error {
+	query := `
+		INSERT INTO customer_schema.email_suppressions (recipient_index, reason, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (recipient_index) DO UPDATE SET reason = EXCLUDED.reason
+	`
+	_, err := r.db.ExecContext(ctx, query, r.recipientIndex(email), reason)
+	return errors.Wrap(err, "failed to suppress email")
+}
+
+func (r *EmailDeliveryRepository) Unsuppress(ctx context.Context, email string) error {
+	query := `DELETE FROM customer_schema.email_suppressions WHERE recipient_index = $1`
+	_, err := r.db.ExecContext(ctx, query, r.recipientIndex(email))
+	return errors.Wrap(err, "failed to lift email suppression")
+}
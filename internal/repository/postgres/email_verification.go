@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type EmailVerificationRepository struct {
+	db *sqlx.DB
+}
+
+func NewEmailVerificationRepository(db *sqlx.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{db: db}
+}
+
+func (r *EmailVerificationRepository) CreateVerificationToken(ctx context.Context, t *domain.EmailVerificationToken) error {
+	query := `
+		INSERT INTO customer_schema.email_verification_tokens (id, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := r.db.ExecContext(ctx, query, t.ID, t.UserID, t.ExpiresAt, t.CreatedAt)
+	return errors.Wrap(err, "failed to create verification token")
+}
+
+func (r *EmailVerificationRepository) LatestVerificationToken(ctx context.Context, userID uuid.UUID) (*domain.EmailVerificationToken, error) {
+	var t domain.EmailVerificationToken
+	query := `
+		SELECT id, user_id, expires_at, consumed_at, created_at
+		FROM customer_schema.email_verification_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	err := r.db.GetContext(ctx, &t, query, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get verification token")
+	}
+	return &t, nil
+}
+
+func (r *EmailVerificationRepository) CountVerificationTokensSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM customer_schema.email_verification_tokens WHERE user_id = $1 AND created_at >= $2`
+	if err := r.db.GetContext(ctx, &count, query, userID, since); err != nil {
+		return 0, errors.Wrap(err, "failed to count verification tokens")
+	}
+	return count, nil
+}
+
+func (r *EmailVerificationRepository) ConsumeVerificationToken(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE customer_schema.email_verification_tokens SET consumed_at = NOW() WHERE id = $1 AND consumed_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return errors.Wrap(err, "failed to consume verification token")
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// Escrows are transactions whose metadata type is ESCROW; their funds stay
+// reserved in the sender's wallet until released or refunded.
+
+func (r *TransactionRepository) ListEscrows(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, int, error) {
+	txs := []*domain.Transaction{}
+	query := `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE metadata->>'type' = 'ESCROW' AND (sender_id = $1 OR receiver_id = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+	if err := r.db.SelectContext(ctx, &txs, query, userID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list escrows")
+	}
+	var total int
+	if err := r.db.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM customer_schema.transactions
+		WHERE metadata->>'type' = 'ESCROW' AND (sender_id = $1 OR receiver_id = $1)
+	`, userID); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count escrows")
+	}
+	return txs, total, nil
+}
+
+func (r *TransactionRepository) FindExpiredEscrows(ctx context.Context, now time.Time, limit int) ([]*domain.Transaction, error) {
+	txs := []*domain.Transaction{}
+	query := `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE status = $1 AND metadata->>'type' = 'ESCROW'
+		  AND (metadata->>'escrow_expiry')::timestamptz <= $2
+		ORDER BY (metadata->>'escrow_expiry')::timestamptz
+		LIMIT $3
+	`
+	if err := r.db.SelectContext(ctx, &txs, query, domain.TransactionStatusReserved, now, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to find expired escrows")
+	}
+	return txs, nil
+}
+
+func (r *TransactionRepository) TransitionEscrow(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error) {
+	return r.TransitionStatus(ctx, tx, from)
+}
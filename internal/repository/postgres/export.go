@@ -0,0 +1,284 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/export"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const exportJobColumns = `
+	id, kind, owner_id, filters, status, total_rows, processed_rows, file_path, file_size,
+	error, requested_by, started_at, completed_at, expires_at, created_at, updated_at
+`
+
+type ExportJobRepository struct {
+	db *sqlx.DB
+}
+
+func NewExportJobRepository(db *sqlx.DB) *ExportJobRepository {
+	return &ExportJobRepository{db: db}
+}
+
+const insertExportJob = `
+	INSERT INTO admin_schema.export_jobs (` + exportJobColumns + `)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+`
+
+func exportJobArgs(j *domain.ExportJob) []interface{} {
+	return []interface{}{
+		j.ID, j.Kind, j.OwnerID, j.Filters, j.Status, j.TotalRows, j.ProcessedRows, j.FilePath, j.FileSize,
+		j.Error, j.RequestedBy, j.StartedAt, j.CompletedAt, j.ExpiresAt, j.CreatedAt, j.UpdatedAt,
+	}
+}
+
+func (r *ExportJobRepository) CreateExportJob(ctx context.Context, j *domain.ExportJob) error {
+	_, err := r.db.ExecContext(ctx, insertExportJob, exportJobArgs(j)...)
+	return errors.Wrap(err, "failed to create export job")
+}
+
+func (r *ExportJobRepository) GetExportJob(ctx context.Context, id uuid.UUID) (*domain.ExportJob, error) {
+	var j domain.ExportJob
+	err := r.db.GetContext(ctx, &j, `SELECT `+exportJobColumns+` FROM admin_schema.export_jobs WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, export.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get export job")
+	}
+	return &j, nil
+}
+
+func (r *ExportJobRepository) ListExportJobs(ctx context.Context, owner *uuid.UUID, limit, offset int) ([]domain.ExportJob, int, error) {
+	where := ""
+	args := []interface{}{limit, offset}
+	if owner != nil {
+		where = "WHERE owner_id = $3"
+		args = append(args, *owner)
+	}
+	var items []domain.ExportJob
+	query := `
+		SELECT ` + exportJobColumns + `
+		FROM admin_schema.export_jobs
+		` + where + `
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`
+	if err := r.db.SelectContext(ctx, &items, query, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list export jobs")
+	}
+	var total int
+	countWhere := strings.Replace(where, "$3", "$1", 1)
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.export_jobs `+countWhere, args[2:]...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count export jobs")
+	}
+	return items, total, nil
+}
+
+func (r *ExportJobRepository) ClaimExportJob(ctx context.Context, now, staleBefore time.Time) (*domain.ExportJob, error) {
+	var j domain.ExportJob
+	query := `
+		UPDATE admin_schema.export_jobs
+		SET status = 'running', started_at = $1, updated_at = $1, processed_rows = 0
+		WHERE id = (
+			SELECT id FROM admin_schema.export_jobs
+			WHERE status = 'queued' OR (status = 'running' AND updated_at < $2)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + exportJobColumns
+	err := r.db.GetContext(ctx, &j, query, now, staleBefore)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim export job")
+	}
+	return &j, nil
+}
+
+func (r *ExportJobRepository) UpdateExportProgress(ctx context.Context, id uuid.UUID, processed, total int) error {
+	query := `
+		UPDATE admin_schema.export_jobs
+		SET processed_rows = $2, total_rows = $3, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, processed, total)
+	return errors.Wrap(err, "failed to update export progress")
+}
+
+func (r *ExportJobRepository) CompleteExportJob(ctx context.Context, j *domain.ExportJob) error {
+	query := `
+		UPDATE admin_schema.export_jobs
+		SET status = $2, total_rows = $3, processed_rows = $4, file_path = $5, file_size = $6,
+			completed_at = $7, expires_at = $8, updated_at = $9, error = NULL
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		j.ID, j.Status, j.TotalRows, j.ProcessedRows, j.FilePath, j.FileSize, j.CompletedAt, j.ExpiresAt, j.UpdatedAt)
+	return errors.Wrap(err, "failed to complete export job")
+}
+
+func (r *ExportJobRepository) FailExportJob(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `
+		UPDATE admin_schema.export_jobs
+		SET status = 'failed', error = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, reason)
+	return errors.Wrap(err, "failed to mark export job failed")
+}
+
+func (r *ExportJobRepository) ListExpiredExportJobs(ctx context.Context, now time.Time, limit int) ([]domain.ExportJob, error) {
+	var items []domain.ExportJob
+	query := `
+		SELECT ` + exportJobColumns + `
+		FROM admin_schema.export_jobs
+		WHERE status = 'completed' AND expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+	`
+	if err := r.db.SelectContext(ctx, &items, query, now, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to list expired export jobs")
+	}
+	return items, nil
+}
+
+func (r *ExportJobRepository) MarkExportJobExpired(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE admin_schema.export_jobs
+		SET status = 'expired', file_path = '', updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return errors.Wrap(err, "failed to mark export job expired")
+}
+
+// exportWhere builds the filter and keyset conditions shared by the export
+// queries. alias prefixes column names.
+func exportWhere(alias string, f domain.ExportFilters, after *export.Cursor, userCol string, extra func(add func(string, interface{}))) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+	add := func(clause string, value interface{}) {
+		args = append(args, value)
+		clauses = append(clauses, fmt.Sprintf(clause, len(args)))
+	}
+	if f.From != nil {
+		add(alias+"created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add(alias+"created_at < $%d", *f.To)
+	}
+	if f.UserID != nil {
+		add(userCol, *f.UserID)
+	}
+	if extra != nil {
+		extra(add)
+	}
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		clauses = append(clauses, fmt.Sprintf("(%screated_at, %sid) > ($%d, $%d)", alias, alias, len(args)-1, len(args)))
+	}
+	if len(clauses) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(clauses, " AND "), args
+}
+
+func (r *AuditRepository) CountAuditLogsForExport(ctx context.Context, f domain.ExportFilters) (int, error) {
+	where, args := exportWhere("a.", f, nil, "a.user_id = $%d", nil)
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.audit_logs a `+where, args...); err != nil {
+		return 0, errors.Wrap(err, "failed to count audit logs for export")
+	}
+	return total, nil
+}
+
+// ExportAuditLogs returns audit logs in ascending (created_at, id) order.
+func (r *AuditRepository) ExportAuditLogs(ctx context.Context, q export.Query) ([]*domain.AuditLog, error) {
+	where, args := exportWhere("a.", q.Filters, q.After, "a.user_id = $%d", nil)
+	args = append(args, q.Limit)
+	query := `
+		SELECT
+			a.id, a.user_id, a.action, COALESCE(a.entity_type, '') AS entity_type,
+			COALESCE(a.entity_id::text, '') AS entity_id,
+			COALESCE(a.old_values, '{}'::jsonb) AS old_values, COALESCE(a.new_values, '{}'::jsonb) AS new_values,
+			COALESCE(a.ip_address, '') AS ip_address, COALESCE(a.user_agent, '') AS user_agent,
+			COALESCE(a.request_id, '') AS request_id, COALESCE(a.status_code, 0) AS status_code,
+			COALESCE(a.error_message, '') AS error_message, a.created_at,
+			COALESCE(u.email, '') AS user_email
+		FROM admin_schema.audit_logs a
+		LEFT JOIN customer_schema.users u ON a.user_id = u.id
+		` + where + `
+		ORDER BY a.created_at, a.id
+		LIMIT $` + fmt.Sprint(len(args))
+
+	var logs []*domain.AuditLog
+	if err := r.db.SelectContext(ctx, &logs, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to export audit logs")
+	}
+	for _, log := range logs {
+		if log.UserEmail != "" {
+			if decrypted, err := r.crypto.Decrypt(log.UserEmail); err == nil {
+				log.UserEmail = decrypted
+			}
+		}
+	}
+	return logs, nil
+}
+
+func transactionExportFilters(f domain.ExportFilters) func(add func(string, interface{})) {
+	return func(add func(string, interface{})) {
+		if s := strings.TrimSpace(f.Status); s != "" {
+			add("status = $%d", s)
+		}
+		if c := strings.TrimSpace(f.Currency); c != "" {
+			add("currency = $%d", c)
+		}
+	}
+}
+
+func (r *TransactionRepository) CountTransactionsForExport(ctx context.Context, f domain.ExportFilters) (int, error) {
+	where, args := exportWhere("", f, nil, "(sender_id = $%[1]d OR receiver_id = $%[1]d)", transactionExportFilters(f))
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.transactions `+where, args...); err != nil {
+		return 0, errors.Wrap(err, "failed to count transactions for export")
+	}
+	return total, nil
+}
+
+// ExportTransactions returns transactions in ascending (created_at, id) order.
+func (r *TransactionRepository) ExportTransactions(ctx context.Context, q export.Query) ([]*domain.Transaction, error) {
+	where, args := exportWhere("", q.Filters, q.After, "(sender_id = $%[1]d OR receiver_id = $%[1]d)", transactionExportFilters(q.Filters))
+	args = append(args, q.Limit)
+	query := `
+		SELECT
+			id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			amount, currency, exchange_rate, converted_amount, converted_currency,
+			fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
+			status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel,
+			COALESCE(category, '') AS category, COALESCE(description, '') AS description,
+			metadata, COALESCE(blockchain_tx_hash, '') AS blockchain_tx_hash, settlement_id, initiated_at, completed_at,
+			created_at, updated_at
+		FROM customer_schema.transactions
+		` + where + `
+		ORDER BY created_at, id
+		LIMIT $` + fmt.Sprint(len(args))
+
+	var txs []*domain.Transaction
+	if err := r.db.SelectContext(ctx, &txs, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to export transactions")
+	}
+	return txs, nil
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type FeeScheduleRepository struct {
+	db *sqlx.DB
+}
+
+func NewFeeScheduleRepository(db *sqlx.DB) *FeeScheduleRepository {
+	return &FeeScheduleRepository{db: db}
+}
+
+const feeScheduleColumns = `id, name, source_currency, destination_currency, channel, user_type, kyc_level,
+	flat_fee, percentage, min_fee, max_fee, effective_from, effective_to, created_by, created_at, updated_at`
+
+func (r *FeeScheduleRepository) Create(ctx context.Context, f *domain.FeeSchedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.fee_schedules (`+feeScheduleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`, f.ID, f.Name, f.SourceCurrency, f.DestinationCurrency, f.Channel, f.UserType, f.KYCLevel,
+		f.FlatFee, f.Percentage, f.MinFee, f.MaxFee, f.EffectiveFrom, f.EffectiveTo, f.CreatedBy, f.CreatedAt, f.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create fee schedule")
+	}
+	return nil
+}
+
+func (r *FeeScheduleRepository) Update(ctx context.Context, f *domain.FeeSchedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.fee_schedules
+		SET name = $2, source_currency = $3, destination_currency = $4, channel = $5, user_type = $6, kyc_level = $7,
+			flat_fee = $8, percentage = $9, min_fee = $10, max_fee = $11, effective_from = $12, effective_to = $13,
+			updated_at = $14
+		WHERE id = $1
+	`, f.ID, f.Name, f.SourceCurrency, f.DestinationCurrency, f.Channel, f.UserType, f.KYCLevel,
+		f.FlatFee, f.Percentage, f.MinFee, f.MaxFee, f.EffectiveFrom, f.EffectiveTo, f.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to update fee schedule")
+	}
+	return nil
+}
+
+func (r *FeeScheduleRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.fee_schedules WHERE id = $1`, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete fee schedule")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *FeeScheduleRepository) Find(ctx context.Context, id uuid.UUID) (*domain.FeeSchedule, error) {
+	var f domain.FeeSchedule
+	err := r.db.GetContext(ctx, &f, `SELECT `+feeScheduleColumns+` FROM admin_schema.fee_schedules WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find fee schedule")
+	}
+	return &f, nil
+}
+
+func (r *FeeScheduleRepository) List(ctx context.Context, since *time.Time) ([]domain.FeeSchedule, error) {
+	schedules := []domain.FeeSchedule{}
+	err := r.db.SelectContext(ctx, &schedules, `
+		SELECT `+feeScheduleColumns+` FROM admin_schema.fee_schedules
+		WHERE $1::timestamptz IS NULL OR effective_to IS NULL OR effective_to > $1
+		ORDER BY effective_from DESC, created_at DESC
+	`, since)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list fee schedules")
+	}
+	return schedules, nil
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeScheduleRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewFeeScheduleRepository(db)
+	// Far ahead, so no real payment is ever priced by these
+	from := time.Date(2990, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := from.AddDate(0, 1, 0)
+
+	mwk, minFee := domain.MWK, decimal.NewFromInt(500)
+	ended := testFeeSchedule(from, &end)
+	ended.SourceCurrency, ended.FlatFee, ended.MinFee = &mwk, minFee, &minFee
+	open := testFeeSchedule(from.AddDate(0, 0, 1), nil)
+	for _, f := range []*domain.FeeSchedule{ended, open} {
+		require.NoError(t, repo.Create(ctx, f))
+		id := f.ID
+		t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.fee_schedules WHERE id = $1`, id) })
+	}
+
+	got, err := repo.Find(ctx, ended.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, domain.MWK, *got.SourceCurrency)
+	assert.True(t, got.FlatFee.Equal(decimal.NewFromInt(500)))
+	assert.True(t, got.MinFee.Equal(decimal.NewFromInt(500)))
+	assert.Nil(t, got.MaxFee)
+
+	all, err := repo.List(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{open.ID, ended.ID}, feeScheduleIDs(all, ended.ID, open.ID), "latest first")
+	current, err := repo.List(ctx, &end)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{open.ID}, feeScheduleIDs(current, ended.ID, open.ID), "ended by then")
+
+	open.Percentage, open.EffectiveTo = decimal.RequireFromString("0.0125"), &end
+	open.UpdatedAt = from
+	require.NoError(t, repo.Update(ctx, open))
+	got, err = repo.Find(ctx, open.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "0.0125", got.Percentage.String())
+	assert.True(t, got.EffectiveTo.Equal(end))
+
+	deleted, err := repo.Delete(ctx, open.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = repo.Delete(ctx, open.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	got, err = repo.Find(ctx, open.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+// testFeeSchedule is an unsaved catch-all 1% schedule effective from from
+// to to.
+func testFeeSchedule(from time.Time, to *time.Time) *domain.FeeSchedule {
+	return &domain.FeeSchedule{
+		ID:            uuid.New(),
+		Name:          "test",
+		Percentage:    decimal.RequireFromString("0.01"),
+		EffectiveFrom: from,
+		EffectiveTo:   to,
+		CreatedAt:     from,
+		UpdatedAt:     from,
+	}
+}
+
+// feeScheduleIDs lists the IDs of schedules among ids, in order.
+func feeScheduleIDs(schedules []domain.FeeSchedule, ids ...uuid.UUID) []uuid.UUID {
+	var out []uuid.UUID
+	for _, f := range schedules {
+		for _, id := range ids {
+			if f.ID == id {
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
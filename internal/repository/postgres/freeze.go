@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// FreezeRepository keeps money movement freezes and their audit trail.
+type FreezeRepository struct {
+	db *sqlx.DB
+}
+
+func NewFreezeRepository(db *sqlx.DB) *FreezeRepository {
+	return &FreezeRepository{db: db}
+}
+
+const movementFreezeColumns = `
+	id, scope, corridor, status, reason, message, requested_by, requested_at,
+	approved_by, activated_at, rejected_by, lift_requested_by, lift_requested_at,
+	lifted_by, lifted_at, updated_at`
+
+// Create saves a proposed freeze and its first event in one transaction.
+func (r *FreezeRepository) Create(ctx context.Context, f *domain.MovementFreeze, e *domain.FreezeEvent) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExecContext(ctx, `
+		INSERT INTO admin_schema.movement_freezes (`+movementFreezeColumns+`)
+		VALUES (
+			:id, :scope, :corridor, :status, :reason, :message, :requested_by, :requested_at,
+			:approved_by, :activated_at, :rejected_by, :lift_requested_by, :lift_requested_at,
+			:lifted_by, :lifted_at, :updated_at)
+	`, f)
+	if err != nil {
+		return errors.Wrap(err, "failed to create freeze")
+	}
+	if err := insertFreezeEvent(ctx, tx, e); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit freeze")
+	}
+	return nil
+}
+
+// Get returns nil when there is no such freeze.
+func (r *FreezeRepository) Get(ctx context.Context, id uuid.UUID) (*domain.MovementFreeze, error) {
+	var f domain.MovementFreeze
+	err := r.db.GetContext(ctx, &f, `SELECT`+movementFreezeColumns+` FROM admin_schema.movement_freezes WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get freeze")
+	}
+	return &f, nil
+}
+
+// Update saves f and records e in one transaction, unless f was updated
+// since prev, in which case it reports false and changes nothing.
+func (r *FreezeRepository) Update(ctx context.Context, f *domain.MovementFreeze, prev time.Time, e *domain.FreezeEvent) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_schema.movement_freezes
+		SET status = $1, approved_by = $2, activated_at = $3, rejected_by = $4,
+			lift_requested_by = $5, lift_requested_at = $6, lifted_by = $7, lifted_at = $8,
+			updated_at = $9
+		WHERE id = $10 AND updated_at = $11
+	`, f.Status, f.ApprovedBy, f.ActivatedAt, f.RejectedBy,
+		f.LiftRequestedBy, f.LiftRequestedAt, f.LiftedBy, f.LiftedAt,
+		f.UpdatedAt, f.ID, prev)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update freeze")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := insertFreezeEvent(ctx, tx, e); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit freeze")
+	}
+	return true, nil
+}
+
+func insertFreezeEvent(ctx context.Context, tx *sqlx.Tx, e *domain.FreezeEvent) error {
+	_, err := tx.NamedExecContext(ctx, `
+		INSERT INTO admin_schema.movement_freeze_events (id, freeze_id, action, actor_id, note, created_at)
+		VALUES (:id, :freeze_id, :action, :actor_id, :note, :created_at)
+	`, e)
+	if err != nil {
+		return errors.Wrap(err, "failed to record freeze event")
+	}
+	return nil
+}
+
+// Open returns the freezes awaiting approval or active.
+func (r *FreezeRepository) Open(ctx context.Context) ([]domain.MovementFreeze, error) {
+	freezes := []domain.MovementFreeze{}
+	err := r.db.SelectContext(ctx, &freezes, `
+		SELECT`+movementFreezeColumns+`
+		FROM admin_schema.movement_freezes
+		WHERE status IN ($1, $2)
+		ORDER BY requested_at
+	`, domain.FreezePending, domain.FreezeActive)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load open freezes")
+	}
+	return freezes, nil
+}
+
+func (r *FreezeRepository) List(ctx context.Context, status domain.FreezeStatus, limit, offset int) ([]domain.MovementFreeze, int, error) {
+	where := `WHERE ($1 = '' OR status = $1)`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.movement_freezes `+where, status); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count freezes")
+	}
+	freezes := []domain.MovementFreeze{}
+	err := r.db.SelectContext(ctx, &freezes, `
+		SELECT`+movementFreezeColumns+`
+		FROM admin_schema.movement_freezes `+where+`
+		ORDER BY requested_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list freezes")
+	}
+	return freezes, total, nil
+}
+
+// Events returns a freeze's audit trail, oldest first.
+func (r *FreezeRepository) Events(ctx context.Context, id uuid.UUID) ([]domain.FreezeEvent, error) {
+	events := []domain.FreezeEvent{}
+	err := r.db.SelectContext(ctx, &events, `
+		SELECT id, freeze_id, action, actor_id, note, created_at
+		FROM admin_schema.movement_freeze_events
+		WHERE freeze_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list freeze events")
+	}
+	return events, nil
+}
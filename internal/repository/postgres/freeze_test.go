@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezeRepository_UpdateIsConditional(t *testing.T) {
+	db := testDB(t)
+	repo := NewFreezeRepository(db)
+	ctx := context.Background()
+
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	f := &domain.MovementFreeze{
+		ID: uuid.New(), Scope: domain.FreezeCorridor, Corridor: "MWK-ZAR", Status: domain.FreezePending,
+		Reason: "rail outage", Message: "Paused.", RequestedBy: uuid.New(), RequestedAt: at, UpdatedAt: at,
+	}
+	event := func(action domain.FreezeAction) *domain.FreezeEvent {
+		return &domain.FreezeEvent{ID: uuid.New(), FreezeID: f.ID, Action: action, ActorID: uuid.New(), CreatedAt: f.UpdatedAt}
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM admin_schema.movement_freeze_events WHERE freeze_id = $1`, f.ID)
+		db.Exec(`DELETE FROM admin_schema.movement_freezes WHERE id = $1`, f.ID)
+	})
+	require.NoError(t, repo.Create(ctx, f, event(domain.FreezeActionRequested)))
+
+	approver := uuid.New()
+	approved := *f
+	approved.Status, approved.ApprovedBy, approved.ActivatedAt = domain.FreezeActive, &approver, &at
+	approved.UpdatedAt = at.Add(time.Second)
+	ok, err := repo.Update(ctx, &approved, at.Add(-time.Second), event(domain.FreezeActionApproved))
+	require.NoError(t, err)
+	assert.False(t, ok, "stale read")
+
+	ok, err = repo.Update(ctx, &approved, at, event(domain.FreezeActionApproved))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.Update(ctx, &approved, at, event(domain.FreezeActionApproved))
+	require.NoError(t, err)
+	assert.False(t, ok, "a second approver loses")
+
+	got, err := repo.Get(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.FreezeActive, got.Status)
+	assert.Equal(t, &approver, got.ApprovedBy)
+	events, err := repo.Events(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Len(t, events, 2, "no event for the refused updates")
+
+	open, err := repo.Open(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, freezeIDs(open), f.ID)
+
+	lifted := approved
+	lifted.Status, lifted.UpdatedAt = domain.FreezeLifted, at.Add(2*time.Second)
+	ok, err = repo.Update(ctx, &lifted, approved.UpdatedAt, event(domain.FreezeActionLifted))
+	require.NoError(t, err)
+	require.True(t, ok)
+	open, err = repo.Open(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, freezeIDs(open), f.ID, "lifted freezes are not open")
+}
+
+func freezeIDs(freezes []domain.MovementFreeze) []uuid.UUID {
+	ids := make([]uuid.UUID, len(freezes))
+	for i, f := range freezes {
+		ids[i] = f.ID
+	}
+	return ids
+}
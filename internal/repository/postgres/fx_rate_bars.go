@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// RateHistoryRepository keeps the OHLC bars of historical FX rates.
+type RateHistoryRepository struct {
+	db *sqlx.DB
+}
+
+func NewRateHistoryRepository(db *sqlx.DB) *RateHistoryRepository {
+	return &RateHistoryRepository{db: db}
+}
+
+const rateBarColumns = `
+	base_currency, target_currency, resolution, bucket_start,
+	open, high, low, close, samples, first_at, last_at`
+
+// RecordSample folds a rate sampled at into the bar of each resolution
+// containing it. Samples may arrive out of order: the open and close are
+// those of the earliest and latest sample.
+func (r *RateHistoryRepository) RecordSample(ctx context.Context, from, to domain.Currency, rate decimal.Decimal, at time.Time) error {
+	values := make([]string, 0, len(domain.RateResolutions))
+	args := []interface{}{from, to, rate, at}
+	for _, res := range domain.RateResolutions {
+		args = append(args, res, res.BucketStart(at))
+		n := len(args)
+		values = append(values, fmt.Sprintf("($1, $2, $%d, $%d, $3, $3, $3, $3, 1, $4, $4)", n-1, n))
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.fx_rate_bars (`+rateBarColumns+`)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (base_currency, target_currency, resolution, bucket_start) DO UPDATE SET
+			open = CASE WHEN EXCLUDED.first_at < fx_rate_bars.first_at THEN EXCLUDED.open ELSE fx_rate_bars.open END,
+			high = GREATEST(fx_rate_bars.high, EXCLUDED.high),
+			low = LEAST(fx_rate_bars.low, EXCLUDED.low),
+			close = CASE WHEN EXCLUDED.last_at >= fx_rate_bars.last_at THEN EXCLUDED.close ELSE fx_rate_bars.close END,
+			samples = fx_rate_bars.samples + 1,
+			first_at = LEAST(fx_rate_bars.first_at, EXCLUDED.first_at),
+			last_at = GREATEST(fx_rate_bars.last_at, EXCLUDED.last_at)
+	`, args...)
+	if err != nil {
+		return errors.Wrap(err, "failed to record rate sample")
+	}
+	return nil
+}
+
+// Bars returns a pair's bars starting in [start, end), oldest first.
+func (r *RateHistoryRepository) Bars(ctx context.Context, from, to domain.Currency, res domain.RateResolution, start, end time.Time) ([]domain.RateBar, error) {
+	bars := []domain.RateBar{}
+	err := r.db.SelectContext(ctx, &bars, `
+		SELECT`+rateBarColumns+`
+		FROM customer_schema.fx_rate_bars
+		WHERE base_currency = $1 AND target_currency = $2 AND resolution = $3
+			AND bucket_start >= $4 AND bucket_start < $5
+		ORDER BY bucket_start
+	`, from, to, res, start, end)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list rate bars")
+	}
+	return bars, nil
+}
+
+// BarAt returns the bar covering at, or nil when none is kept.
+func (r *RateHistoryRepository) BarAt(ctx context.Context, from, to domain.Currency, res domain.RateResolution, at time.Time) (*domain.RateBar, error) {
+	var bar domain.RateBar
+	err := r.db.GetContext(ctx, &bar, `
+		SELECT`+rateBarColumns+`
+		FROM customer_schema.fx_rate_bars
+		WHERE base_currency = $1 AND target_currency = $2 AND resolution = $3 AND bucket_start = $4
+	`, from, to, res, res.BucketStart(at))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get rate bar")
+	}
+	return &bar, nil
+}
+
+// PruneBars deletes the bars of one resolution starting before before.
+func (r *RateHistoryRepository) PruneBars(ctx context.Context, res domain.RateResolution, before time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM customer_schema.fx_rate_bars WHERE resolution = $1 AND bucket_start < $2
+	`, res, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to prune rate bars")
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
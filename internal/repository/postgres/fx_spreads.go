@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FXSpreadRepository keeps the FX spreads set per currency pair and reads
+// what they earned.
+type FXSpreadRepository struct {
+	db *sqlx.DB
+}
+
+func NewFXSpreadRepository(db *sqlx.DB) *FXSpreadRepository {
+	return &FXSpreadRepository{db: db}
+}
+
+const fxSpreadColumns = `id, base_currency, target_currency, unit, value, pip_size, note, updated_by, created_at, updated_at`
+
+func (r *FXSpreadRepository) Save(ctx context.Context, s *domain.FXSpread) error {
+	_, err := r.db.NamedExecContext(ctx, `
+		INSERT INTO admin_schema.fx_spreads (`+fxSpreadColumns+`)
+		VALUES (:id, :base_currency, :target_currency, :unit, :value, :pip_size, :note, :updated_by, :created_at, :updated_at)
+		ON CONFLICT (base_currency, target_currency) DO UPDATE SET
+			unit = EXCLUDED.unit, value = EXCLUDED.value, pip_size = EXCLUDED.pip_size, note = EXCLUDED.note,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`, s)
+	if err != nil {
+		return errors.Wrap(err, "failed to save fx spread")
+	}
+	return nil
+}
+
+func (r *FXSpreadRepository) Delete(ctx context.Context, from, to domain.Currency) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM admin_schema.fx_spreads WHERE base_currency = $1 AND target_currency = $2
+	`, from, to)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete fx spread")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *FXSpreadRepository) Find(ctx context.Context, from, to domain.Currency) (*domain.FXSpread, error) {
+	var s domain.FXSpread
+	err := r.db.GetContext(ctx, &s, `
+		SELECT `+fxSpreadColumns+` FROM admin_schema.fx_spreads WHERE base_currency = $1 AND target_currency = $2
+	`, from, to)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find fx spread")
+	}
+	return &s, nil
+}
+
+func (r *FXSpreadRepository) List(ctx context.Context) ([]domain.FXSpread, error) {
+	spreads := []domain.FXSpread{}
+	err := r.db.SelectContext(ctx, &spreads, `
+		SELECT `+fxSpreadColumns+` FROM admin_schema.fx_spreads ORDER BY base_currency, target_currency
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list fx spreads")
+	}
+	return spreads, nil
+}
+
+// Revenue books each payment as the ledger does: what the receiver would
+// have got at the recorded mid rate, rounded to the cent, less what they
+// got.
+func (r *FXSpreadRepository) Revenue(ctx context.Context, f domain.FXRevenueFilter) ([]domain.FXRevenue, error) {
+	rows := []domain.FXRevenue{}
+	err := r.db.SelectContext(ctx, &rows, `
+		WITH priced AS (
+			SELECT created_at, currency, converted_currency, amount, converted_amount,
+				(metadata->>'fx_mid_rate')::numeric AS mid
+			FROM customer_schema.transactions
+			WHERE status = 'completed' AND currency <> converted_currency
+				AND created_at >= $1 AND created_at < $2
+				AND ($3 = '' OR (currency = $3 AND converted_currency = $4))
+				AND metadata ? 'fx_mid_rate'
+		)
+		SELECT (created_at AT TIME ZONE 'UTC')::date AS day,
+			currency AS base_currency, converted_currency AS target_currency,
+			COUNT(*) AS payments,
+			SUM(amount) AS volume,
+			SUM(converted_amount) AS converted,
+			SUM(ROUND(amount * mid, 2) - converted_amount) AS revenue,
+			SUM((ROUND(amount * mid, 2) - converted_amount) / mid) AS base_revenue
+		FROM priced
+		WHERE mid > 0
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3
+	`, f.From, f.To, string(f.BaseCurrency), string(f.TargetCurrency))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to report fx revenue")
+	}
+	return rows, nil
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFXSpreadRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewFXSpreadRepository(db)
+	day := time.Date(1990, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("one spread per pair", func(t *testing.T) {
+		// ISO 4217 test codes, so no real pair is touched
+		from, to := domain.Currency("XTS"), domain.Currency("XXX")
+		t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.fx_spreads WHERE base_currency = $1`, from) })
+		sp := &domain.FXSpread{
+			ID: uuid.New(), BaseCurrency: from, TargetCurrency: to, Unit: domain.FXSpreadPercent,
+			Value: decimal.NewFromInt(1), UpdatedBy: uuid.New(), CreatedAt: day, UpdatedAt: day,
+		}
+		require.NoError(t, repo.Save(ctx, sp))
+		replaced := *sp
+		pip := decimal.RequireFromString("0.0001")
+		replaced.ID, replaced.Unit, replaced.Value, replaced.PipSize, replaced.UpdatedAt = uuid.New(), domain.FXSpreadPips, decimal.NewFromInt(25), &pip, day.Add(time.Hour)
+		require.NoError(t, repo.Save(ctx, &replaced))
+
+		got, err := repo.Find(ctx, from, to)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, sp.ID, got.ID, "replaced in place")
+		assert.Equal(t, domain.FXSpreadPips, got.Unit)
+		assert.True(t, got.PipSize.Equal(pip))
+		assert.True(t, got.UpdatedAt.Equal(day.Add(time.Hour)))
+		none, err := repo.Find(ctx, to, from)
+		require.NoError(t, err)
+		assert.Nil(t, none, "one way only")
+
+		deleted, err := repo.Delete(ctx, from, to)
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		deleted, err = repo.Delete(ctx, from, to)
+		require.NoError(t, err)
+		assert.False(t, deleted)
+	})
+
+	t.Run("revenue per day and corridor", func(t *testing.T) {
+		user := testUser(t, db, day)
+		wallet := testWallet(t, db, user, domain.MWK, domain.WalletStatusActive)
+		payment := func(at time.Time, amount int64, converted, mid string, status domain.TransactionStatus) {
+			id := testCredit(t, db, user, wallet, amount, status, "", at)
+			metadata := `{}`
+			if mid != "" {
+				metadata = `{"fx_mid_rate": "` + mid + `"}`
+			}
+			_, err := db.Exec(`
+				UPDATE customer_schema.transactions
+				SET converted_currency = 'ZAR', converted_amount = $2, metadata = $3, created_at = $4
+				WHERE id = $1
+			`, id, converted, metadata, at)
+			require.NoError(t, err)
+		}
+		payment(day.Add(time.Hour), 1000, "9.50", "0.01", domain.TransactionStatusCompleted)
+		payment(day.Add(2*time.Hour), 500, "4.75", "0.01", domain.TransactionStatusCompleted)
+		payment(day.Add(26*time.Hour), 200, "3.90", "0.02", domain.TransactionStatusCompleted)
+		payment(day.Add(3*time.Hour), 700, "7.00", "0.01", domain.TransactionStatusPending)
+		payment(day.Add(4*time.Hour), 700, "7.00", "", domain.TransactionStatusCompleted)
+		payment(day.AddDate(0, 0, 2), 700, "6.00", "0.01", domain.TransactionStatusCompleted)
+
+		rows, err := repo.Revenue(ctx, domain.FXRevenueFilter{From: day, To: day.AddDate(0, 0, 2), BaseCurrency: domain.MWK, TargetCurrency: domain.ZAR})
+		require.NoError(t, err)
+		require.Len(t, rows, 2, "pending, unpriced and later payments are left out")
+		first := rows[0]
+		assert.True(t, first.Day.Equal(day))
+		assert.Equal(t, 2, first.Payments)
+		assert.Equal(t, "1500", first.Volume.String())
+		assert.Equal(t, "14.25", first.Converted.String())
+		assert.Equal(t, "0.75", first.Revenue.String(), "what the mid rate would have paid less what was paid")
+		assert.True(t, first.BaseRevenue.Equal(decimal.NewFromInt(75)), first.BaseRevenue.String())
+		assert.Equal(t, "0.1", rows[1].Revenue.String())
+
+		rows, err = repo.Revenue(ctx, domain.FXRevenueFilter{From: day, To: day.AddDate(0, 0, 2), BaseCurrency: domain.ZAR, TargetCurrency: domain.MWK})
+		require.NoError(t, err)
+		assert.Empty(t, rows, "the other way is another corridor")
+	})
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// HeartbeatRepository keeps the last beat of every background worker of
+// every running process.
+type HeartbeatRepository struct {
+	db *sqlx.DB
+}
+
+func NewHeartbeatRepository(db *sqlx.DB) *HeartbeatRepository {
+	return &HeartbeatRepository{db: db}
+}
+
+// RecordHeartbeats upserts one process's workers. A worker's alert marker
+// is kept, so a stall is alerted on once.
+func (r *HeartbeatRepository) RecordHeartbeats(ctx context.Context, beats []domain.WorkerHeartbeat) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	for _, h := range beats {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO admin_schema.worker_heartbeats (
+				service, worker, instance, interval_seconds, restartable, restarts, started_at, last_beat_at, stopped_at, updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+			ON CONFLICT (service, worker, instance) DO UPDATE SET
+				interval_seconds = EXCLUDED.interval_seconds,
+				restartable = EXCLUDED.restartable,
+				restarts = EXCLUDED.restarts,
+				started_at = EXCLUDED.started_at,
+				last_beat_at = EXCLUDED.last_beat_at,
+				stopped_at = EXCLUDED.stopped_at,
+				updated_at = NOW()
+		`, h.Service, h.Worker, h.Instance, h.IntervalSeconds, h.Restartable, h.Restarts, h.StartedAt, h.LastBeatAt, h.StoppedAt)
+		if err != nil {
+			return errors.Wrap(err, "failed to record worker heartbeat")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit worker heartbeats")
+}
+
+func (r *HeartbeatRepository) ListHeartbeats(ctx context.Context) ([]domain.WorkerHeartbeat, error) {
+	beats := []domain.WorkerHeartbeat{}
+	err := r.db.SelectContext(ctx, &beats, `
+		SELECT service, worker, instance, interval_seconds, restartable, restarts, started_at, last_beat_at,
+			stopped_at, alerted_at, alerted_restarts, updated_at
+		FROM admin_schema.worker_heartbeats
+		ORDER BY service, worker, instance
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list worker heartbeats")
+	}
+	return beats, nil
+}
+
+// ClaimAlert marks h's stall and restarts as alerted at the given time. It
+// reports false when they were already claimed, by this or another
+// process, or the worker beat, restarted or stopped since it was read.
+func (r *HeartbeatRepository) ClaimAlert(ctx context.Context, h domain.WorkerHeartbeat, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.worker_heartbeats
+		SET alerted_at = $6, alerted_restarts = restarts
+		WHERE service = $1 AND worker = $2 AND instance = $3
+			AND last_beat_at = $4 AND restarts = $5 AND stopped_at IS NULL
+			AND (alerted_at IS NULL OR alerted_at < last_beat_at OR alerted_restarts < restarts)
+	`, h.Service, h.Worker, h.Instance, h.LastBeatAt, h.Restarts, at)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to claim worker alert")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// PruneHeartbeats deletes workers not flushed since before, left behind by
+// processes that exited.
+func (r *HeartbeatRepository) PruneHeartbeats(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.worker_heartbeats WHERE updated_at < $1`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to prune worker heartbeats")
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/pkg/errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IdempotencyRepository keeps the idempotency keys of payment initiations
+// while Redis is down; see middleware.IdempotencyStore.
+type IdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Claim takes key for ttl, clearing expired keys first; it reports false
+// when key is already taken.
+func (r *IdempotencyRepository) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `
+		DELETE FROM customer_schema.idempotency_keys WHERE expires_at <= NOW()
+	`); err != nil {
+		return false, errors.Wrap(err, "failed to clear expired idempotency keys")
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.idempotency_keys (key, expires_at)
+		VALUES ($1, NOW() + $2 * INTERVAL '1 millisecond')
+		ON CONFLICT (key) DO NOTHING
+	`, key, ttl.Milliseconds())
+	if err != nil {
+		return false, errors.Wrap(err, "failed to claim idempotency key")
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// Lookup reports whether key is taken and its response, nil while the
+// request holding it is still running.
+func (r *IdempotencyRepository) Lookup(ctx context.Context, key string) (bool, []byte, error) {
+	var response []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT response FROM customer_schema.idempotency_keys
+		WHERE key = $1 AND expires_at > NOW()
+	`, key).Scan(&response)
+	if err == sql.ErrNoRows {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, errors.Wrap(err, "failed to look up idempotency key")
+	}
+	return true, response, nil
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, key string, response []byte) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.idempotency_keys SET response = $2, completed_at = NOW()
+		WHERE key = $1
+	`, key, response)
+	if err != nil {
+		return errors.Wrap(err, "failed to complete idempotency key")
+	}
+	return nil
+}
+
+// Release frees key unless its request completed.
+func (r *IdempotencyRepository) Release(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM customer_schema.idempotency_keys WHERE key = $1 AND completed_at IS NULL
+	`, key)
+	if err != nil {
+		return errors.Wrap(err, "failed to release idempotency key")
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/pkg/logger"
+	"kyd/pkg/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxPreparedStatements caps the statement cache of each repository.
+// Queries built at runtime (IN lists, optional filters) beyond the cap run
+// unprepared instead of growing the cache without bound.
+const maxPreparedStatements = 256
+
+// QueryStats summarises the executions of one repository method.
+type QueryStats struct {
+	Query   string  `json:"query"`
+	Calls   int64   `json:"calls"`
+	Errors  int64   `json:"errors"`
+	Slow    int64   `json:"slow"`
+	Rows    int64   `json:"rows"`
+	TotalMs float64 `json:"total_ms"`
+	MeanMs  float64 `json:"mean_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+type queryRecorder struct {
+	mu      sync.Mutex
+	stats   map[string]*QueryStats
+	slow    time.Duration
+	prepare bool
+	timeout time.Duration
+	logger  logger.Logger
+}
+
+// queries collects instrumentation for every instrumented repository in the
+// process.
+var queries = &queryRecorder{
+	stats:   make(map[string]*QueryStats),
+	prepare: true,
+	logger:  logger.NewNop(),
+}
+
+// ConfigureQueryInstrumentation sets the slow-query threshold (zero disables
+// the slow-query log), whether hot-path repositories cache prepared
+// statements, and where slow queries are logged. Call it before serving.
+func ConfigureQueryInstrumentation(slowThreshold time.Duration, prepare bool, log logger.Logger) {
+	queries.mu.Lock()
+	defer queries.mu.Unlock()
+	queries.slow = slowThreshold
+	queries.prepare = prepare
+	queries.logger = log
+}
+
+// QueryStatsSnapshot returns the statistics gathered so far, most expensive
+// first.
+func QueryStatsSnapshot() []QueryStats {
+	queries.mu.Lock()
+	out := make([]QueryStats, 0, len(queries.stats))
+	for _, s := range queries.stats {
+		c := *s
+		if c.Calls > 0 {
+			c.MeanMs = c.TotalMs / float64(c.Calls)
+		}
+		out = append(out, c)
+	}
+	queries.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].TotalMs > out[j].TotalMs })
+	return out
+}
+
+// SlowQueryThreshold returns the configured slow-query threshold.
+func SlowQueryThreshold() time.Duration {
+	queries.mu.Lock()
+	defer queries.mu.Unlock()
+	return queries.slow
+}
+
+func (q *queryRecorder) record(op, query string, d time.Duration, rows int64, err error) {
+	ms := float64(d) / float64(time.Millisecond)
+	q.mu.Lock()
+	s, ok := q.stats[op]
+	if !ok {
+		s = &QueryStats{Query: op}
+		q.stats[op] = s
+	}
+	s.Calls++
+	s.Rows += rows
+	s.TotalMs += ms
+	if ms > s.MaxMs {
+		s.MaxMs = ms
+	}
+	if err != nil && err != sql.ErrNoRows {
+		s.Errors++
+	}
+	slow := q.slow > 0 && d >= q.slow
+	if slow {
+		s.Slow++
+	}
+	log := q.logger
+	threshold := q.slow
+	q.mu.Unlock()
+
+	if slow {
+		log.Warn("Slow query", map[string]interface{}{
+			"query":        op,
+			"duration_ms":  ms,
+			"threshold_ms": float64(threshold) / float64(time.Millisecond),
+			"rows":         rows,
+			"sql":          compactSQL(query),
+		})
+	}
+}
+
+// instrumentedDB wraps the database handle of a hot-path repository. The
+// query methods those repositories use are traced, timed, counted and, when
+// enabled, run through a per-repository prepared statement cache; everything
+// else passes through to the embedded handle.
+type instrumentedDB struct {
+	*sqlx.DB
+
+	mu    sync.RWMutex
+	stmts map[string]*sqlx.Stmt
+	named map[string]*sqlx.NamedStmt
+}
+
+func instrument(db *sqlx.DB) *instrumentedDB {
+	return &instrumentedDB{
+		DB:    db,
+		stmts: make(map[string]*sqlx.Stmt),
+		named: make(map[string]*sqlx.NamedStmt),
+	}
+}
+
+func (d *instrumentedDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	op := caller()
+	ctx, span := startQuerySpan(ctx, op, query)
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+	var err error
+	if stmt := d.stmt(ctx, query); stmt != nil {
+		err = stmt.GetContext(ctx, dest, args...)
+	} else {
+		err = d.DB.GetContext(ctx, dest, query, args...)
+	}
+	var rows int64
+	if err == nil {
+		rows = 1
+	}
+	queries.record(op, query, time.Since(start), rows, err)
+	endQuerySpan(span, err)
+	return err
+}
+
+func (d *instrumentedDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	op := caller()
+	ctx, span := startQuerySpan(ctx, op, query)
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+	var err error
+	if stmt := d.stmt(ctx, query); stmt != nil {
+		err = stmt.SelectContext(ctx, dest, args...)
+	} else {
+		err = d.DB.SelectContext(ctx, dest, query, args...)
+	}
+	var rows int64
+	if v := reflect.ValueOf(dest); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+		rows = int64(v.Elem().Len())
+	}
+	queries.record(op, query, time.Since(start), rows, err)
+	endQuerySpan(span, err)
+	return err
+}
+
+func (d *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	op := caller()
+	ctx, span := startQuerySpan(ctx, op, query)
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+	var res sql.Result
+	var err error
+	if stmt := d.stmt(ctx, query); stmt != nil {
+		res, err = stmt.ExecContext(ctx, args...)
+	} else {
+		res, err = d.DB.ExecContext(ctx, query, args...)
+	}
+	queries.record(op, query, time.Since(start), affected(res, err), err)
+	endQuerySpan(span, err)
+	return res, err
+}
+
+func (d *instrumentedDB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	op := caller()
+	ctx, span := startQuerySpan(ctx, op, query)
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+	var res sql.Result
+	var err error
+	if stmt := d.namedStmt(ctx, query); stmt != nil {
+		res, err = stmt.ExecContext(ctx, arg)
+	} else {
+		res, err = d.DB.NamedExecContext(ctx, query, arg)
+	}
+	queries.record(op, query, time.Since(start), affected(res, err), err)
+	endQuerySpan(span, err)
+	return res, err
+}
+
+// stmt returns the cached prepared statement for query, preparing it on
+// first use. It returns nil when preparing is disabled, the cache is full,
+// or preparation failed, in which case the caller runs the query directly.
+func (d *instrumentedDB) stmt(ctx context.Context, query string) *sqlx.Stmt {
+	if !preparing() {
+		return nil
+	}
+	d.mu.RLock()
+	s, ok := d.stmts[query]
+	full := len(d.stmts) >= maxPreparedStatements
+	d.mu.RUnlock()
+	if ok || full {
+		return s
+	}
+	prepared, err := d.DB.PreparexContext(ctx, query)
+	if err != nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if existing, ok := d.stmts[query]; ok {
+		prepared.Close()
+		return existing
+	}
+	d.stmts[query] = prepared
+	return prepared
+}
+
+func (d *instrumentedDB) namedStmt(ctx context.Context, query string) *sqlx.NamedStmt {
+	if !preparing() {
+		return nil
+	}
+	d.mu.RLock()
+	s, ok := d.named[query]
+	full := len(d.named) >= maxPreparedStatements
+	d.mu.RUnlock()
+	if ok || full {
+		return s
+	}
+	prepared, err := d.DB.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if existing, ok := d.named[query]; ok {
+		prepared.Close()
+		return existing
+	}
+	d.named[query] = prepared
+	return prepared
+}
+
+// startQuerySpan starts the span of one query, named after the repository
+// method that issued it.
+func startQuerySpan(ctx context.Context, op, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, op,
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.query.text", compactSQL(query)),
+	)
+}
+
+// endQuerySpan ends span; finding no row is not an error.
+func endQuerySpan(span trace.Span, err error) {
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+// withDefaultTimeout bounds calls whose context carries no deadline, such
+// as those from background workers, by the configured query timeout.
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	queries.mu.Lock()
+	timeout := queries.timeout
+	queries.mu.Unlock()
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func preparing() bool {
+	queries.mu.Lock()
+	defer queries.mu.Unlock()
+	return queries.prepare
+}
+
+func affected(res sql.Result, err error) int64 {
+	if err != nil || res == nil {
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return n
+}
+
+// caller names the repository method that issued a query, e.g.
+// "TransactionRepository.FindByID".
+func caller() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+	name := runtime.FuncForPC(pc).Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimPrefix(name, "postgres.")
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
+
+// compactSQL collapses whitespace so a query fits on one log line. Arguments
+// are never logged.
+func compactSQL(query string) string {
+	s := strings.Join(strings.Fields(query), " ")
+	if len(s) > 300 {
+		s = s[:300] + "..."
+	}
+	return s
+}
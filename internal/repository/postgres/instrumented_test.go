@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedRepo struct{}
+
+func (namedRepo) FindThing() string { return issue() }
+
+func issue() string { return caller() }
+
+func TestCaller_NamesRepositoryMethod(t *testing.T) {
+	assert.Equal(t, "namedRepo.FindThing", namedRepo{}.FindThing())
+}
+
+func TestQueryRecorder(t *testing.T) {
+	orig := queries
+	defer func() { queries = orig }()
+	queries = &queryRecorder{stats: make(map[string]*QueryStats), logger: logger.NewNop()}
+	ConfigureQueryInstrumentation(50*time.Millisecond, false, logger.NewNop())
+
+	queries.record("WalletRepository.FindByID", "SELECT 1", 10*time.Millisecond, 1, nil)
+	queries.record("WalletRepository.FindByID", "SELECT 1", 70*time.Millisecond, 0, sql.ErrNoRows)
+	queries.record("TransactionRepository.Create", "INSERT", 5*time.Millisecond, 0, errors.New("boom"))
+
+	stats := QueryStatsSnapshot()
+	require.Len(t, stats, 2)
+	find := stats[0]
+	assert.Equal(t, "WalletRepository.FindByID", find.Query)
+	assert.EqualValues(t, 2, find.Calls)
+	assert.EqualValues(t, 1, find.Rows)
+	assert.EqualValues(t, 1, find.Slow)
+	assert.EqualValues(t, 0, find.Errors, "no rows is not a failure")
+	assert.InDelta(t, 40, find.MeanMs, 0.001)
+	assert.InDelta(t, 70, find.MaxMs, 0.001)
+	assert.EqualValues(t, 1, stats[1].Errors)
+	assert.False(t, preparing())
+}
+
+func TestCompactSQL(t *testing.T) {
+	assert.Equal(t, "SELECT id FROM wallets WHERE id = $1", compactSQL(`
+		SELECT id
+		FROM wallets
+		WHERE id = $1
+	`))
+}
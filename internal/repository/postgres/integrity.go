@@ -0,0 +1,263 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// integrityFindingLimit bounds how many findings one check reports per
+// run, so a systemic problem cannot flood the table.
+const integrityFindingLimit = 1000
+
+// IntegrityRepository runs the integrity checks' queries and keeps their
+// findings and runs.
+type IntegrityRepository struct {
+	db *sqlx.DB
+}
+
+func NewIntegrityRepository(db *sqlx.DB) *IntegrityRepository {
+	return &IntegrityRepository{db: db}
+}
+
+// findings runs a check query selecting resource_type, resource_id, detail
+// and severity.
+func (r *IntegrityRepository) findings(ctx context.Context, check domain.IntegrityCheck, query string, args ...interface{}) ([]domain.IntegrityFinding, error) {
+	var found []domain.IntegrityFinding
+	if err := r.db.SelectContext(ctx, &found, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to run integrity check "+string(check))
+	}
+	for i := range found {
+		found[i].Check = check
+	}
+	return found, nil
+}
+
+// TransactionsWithoutLedger finds transactions that completed before
+// before and should have moved wallet balances, but have no ledger
+// entries.
+func (r *IntegrityRepository) TransactionsWithoutLedger(ctx context.Context, before time.Time) ([]domain.IntegrityFinding, error) {
+	return r.findings(ctx, domain.IntegrityTransactionWithoutLedger, `
+		SELECT 'transaction' AS resource_type, t.id::text AS resource_id,
+			format('completed %s %s of %s %s has no ledger entries', t.transaction_type, t.reference, t.amount, t.currency) AS detail,
+			'high' AS severity
+		FROM customer_schema.transactions t
+		WHERE t.status = 'completed'
+			AND t.transaction_type IN ('payment', 'transfer', 'deposit', 'withdrawal')
+			AND COALESCE(t.completed_at, t.updated_at) < $1
+			AND NOT EXISTS (SELECT 1 FROM customer_schema.ledger_entries e WHERE e.transaction_id = t.id)
+		ORDER BY t.created_at
+		LIMIT $2
+	`, before, integrityFindingLimit)
+}
+
+// WalletsWithoutUsers finds open wallets whose owner is missing or
+// deleted. Wallets still holding money are the more severe.
+func (r *IntegrityRepository) WalletsWithoutUsers(ctx context.Context) ([]domain.IntegrityFinding, error) {
+	return r.findings(ctx, domain.IntegrityWalletWithoutUser, `
+		SELECT 'wallet' AS resource_type, w.id::text AS resource_id,
+			CASE WHEN u.id IS NULL
+				THEN format('%s wallet belongs to missing user %s', w.currency, w.user_id)
+				ELSE format('%s wallet of deleted user %s is %s', w.currency, w.user_id, w.status)
+			END AS detail,
+			CASE WHEN w.ledger_balance > 0 OR w.reserved_balance > 0 THEN 'high' ELSE 'medium' END AS severity
+		FROM customer_schema.wallets w
+		LEFT JOIN customer_schema.users u ON u.id = w.user_id
+		WHERE w.status <> 'closed' AND (u.id IS NULL OR u.user_status = 'deleted')
+		ORDER BY w.created_at
+		LIMIT $1
+	`, integrityFindingLimit)
+}
+
+// integrityReference is a reference the database does not enforce with a
+// foreign key, mostly because it crosses schemas or lives in JSON.
+type integrityReference struct {
+	resourceType string
+	table        string
+	key          string
+	field        string
+	// value is the referencing expression, as text
+	value  string
+	target string
+	where  string
+}
+
+var integrityReferences = []integrityReference{
+	{"transaction", "customer_schema.transactions", "id", "settlement_id", "settlement_id::text", "customer_schema.settlements", ""},
+	{"settlement", "customer_schema.settlements", "id", "metadata.counterparty_id", "metadata->>'counterparty_id'", "admin_schema.counterparties", ""},
+	{"counterparty_prefunding", "admin_schema.counterparty_prefunding", "id", "settlement_id", "settlement_id::text", "customer_schema.settlements", ""},
+	{"ledger_posting", "customer_schema.ledger_postings", "idempotency_key", "transaction_id", "transaction_id::text", "customer_schema.transactions", ""},
+	{"counterparty", "admin_schema.counterparties", "id", "created_by", "created_by::text", "customer_schema.users", ""},
+	{"case", "admin_schema.cases", "id", "entity_id", "entity_id", "customer_schema.users", "entity_type = 'user'"},
+	{"case", "admin_schema.cases", "id", "entity_id", "entity_id", "customer_schema.transactions", "entity_type = 'transaction'"},
+	{"case", "admin_schema.cases", "id", "entity_id", "entity_id", "customer_schema.wallets", "entity_type = 'wallet'"},
+}
+
+func (ref integrityReference) query() string {
+	where := ref.value + " IS NOT NULL AND " + ref.value + " <> ''"
+	if ref.where != "" {
+		where += " AND " + ref.where
+	}
+	return fmt.Sprintf(`
+		SELECT '%s' AS resource_type, t.%s::text AS resource_id,
+			'%s ' || (%s) || ' does not exist in %s' AS detail,
+			'medium' AS severity
+		FROM %s t
+		WHERE %s AND NOT EXISTS (SELECT 1 FROM %s x WHERE x.id::text = %s)`,
+		ref.resourceType, ref.key, ref.field, ref.value, ref.target, ref.table, where, ref.target, ref.value)
+}
+
+// DanglingReferences finds references without a foreign key that point at
+// rows which do not exist.
+func (r *IntegrityRepository) DanglingReferences(ctx context.Context) ([]domain.IntegrityFinding, error) {
+	parts := make([]string, len(integrityReferences))
+	for i, ref := range integrityReferences {
+		parts[i] = ref.query()
+	}
+	return r.findings(ctx, domain.IntegrityDanglingReference,
+		strings.Join(parts, "\n\t\tUNION ALL")+"\n\t\tLIMIT $1", integrityFindingLimit)
+}
+
+// StalePending finds transactions, settlements and KYC documents that have
+// sat in an in-flight state since before before. Transactions queued for
+// their corridor's next window and payments held for approval wait on
+// purpose and are left out.
+func (r *IntegrityRepository) StalePending(ctx context.Context, before time.Time) ([]domain.IntegrityFinding, error) {
+	return r.findings(ctx, domain.IntegrityStalePending, `
+		SELECT 'transaction' AS resource_type, id::text AS resource_id,
+			format('%s %s has been %s since %s', transaction_type, reference, status, to_char(updated_at AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI UTC')) AS detail,
+			'medium' AS severity
+		FROM customer_schema.transactions
+		WHERE status IN ('pending', 'processing', 'reserved', 'settling', 'pending_settlement') AND updated_at < $1
+		UNION ALL
+		SELECT 'settlement', id::text,
+			format('settlement %s has been %s since %s', batch_reference, status, to_char(updated_at AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI UTC')),
+			'high'
+		FROM customer_schema.settlements
+		WHERE status IN ('pending', 'processing', 'submitted') AND updated_at < $1
+		UNION ALL
+		SELECT 'kyc_document', id::text,
+			format('%s document has been %s since %s', document_type, review_status, to_char(updated_at AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI UTC')),
+			'low'
+		FROM customer_schema.kyc_documents
+		WHERE superseded_at IS NULL AND review_status IN ('uploaded', 'scanning', 'scanned_clean') AND updated_at < $1
+		LIMIT $2
+	`, before, integrityFindingLimit)
+}
+
+// CurrentDocuments returns the KYC documents whose files should be in
+// storage: current attempts that were not quarantined.
+func (r *IntegrityRepository) CurrentDocuments(ctx context.Context) ([]domain.KYCDocument, error) {
+	var docs []domain.KYCDocument
+	err := r.db.SelectContext(ctx, &docs, `
+		SELECT * FROM customer_schema.kyc_documents
+		WHERE superseded_at IS NULL AND review_status <> 'quarantined'
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list kyc documents")
+	}
+	return docs, nil
+}
+
+// RecordFindings stores what one run of check found, in one transaction:
+// problems already open are refreshed, new ones are opened, and open
+// findings of check the run did not see are resolved.
+func (r *IntegrityRepository) RecordFindings(ctx context.Context, check domain.IntegrityCheck, found []domain.IntegrityFinding, seenAt time.Time) (opened, resolved int, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	for _, f := range found {
+		var inserted bool
+		err := tx.GetContext(ctx, &inserted, `
+			INSERT INTO admin_schema.integrity_findings (
+				id, check_name, severity, resource_type, resource_id, detail, status, first_seen_at, last_seen_at
+			) VALUES ($1, $2, $3, $4, $5, $6, 'open', $7, $7)
+			ON CONFLICT (check_name, resource_type, resource_id) WHERE status = 'open'
+			DO UPDATE SET severity = EXCLUDED.severity, detail = EXCLUDED.detail, last_seen_at = EXCLUDED.last_seen_at
+			RETURNING (xmax = 0)
+		`, uuid.New(), check, f.Severity, f.ResourceType, f.ResourceID, f.Detail, seenAt)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "failed to record integrity finding")
+		}
+		if inserted {
+			opened++
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_schema.integrity_findings
+		SET status = 'resolved', resolved_at = $2
+		WHERE check_name = $1 AND status = 'open' AND last_seen_at < $2
+	`, check, seenAt)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to resolve integrity findings")
+	}
+	n, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, errors.Wrap(err, "failed to commit integrity findings")
+	}
+	return opened, int(n), nil
+}
+
+// CountOpenFindings returns how many findings are open across all checks.
+func (r *IntegrityRepository) CountOpenFindings(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM admin_schema.integrity_findings WHERE status = 'open'`)
+	return n, errors.Wrap(err, "failed to count integrity findings")
+}
+
+// ListFindings returns findings, most recently seen first, optionally
+// filtered by check and status.
+func (r *IntegrityRepository) ListFindings(ctx context.Context, check domain.IntegrityCheck, status domain.IntegrityFindingStatus, limit, offset int) ([]domain.IntegrityFinding, int, error) {
+	where := `WHERE ($1 = '' OR check_name = $1) AND ($2 = '' OR status = $2)`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.integrity_findings `+where, check, status); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count integrity findings")
+	}
+	findings := []domain.IntegrityFinding{}
+	err := r.db.SelectContext(ctx, &findings, `
+		SELECT id, check_name, severity, resource_type, resource_id, detail, status, first_seen_at, last_seen_at, resolved_at
+		FROM admin_schema.integrity_findings `+where+`
+		ORDER BY last_seen_at DESC, first_seen_at DESC
+		LIMIT $3 OFFSET $4
+	`, check, status, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list integrity findings")
+	}
+	return findings, total, nil
+}
+
+func (r *IntegrityRepository) CreateRun(ctx context.Context, run *domain.IntegrityRun) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.integrity_runs (id, started_at, finished_at, open_findings, opened, resolved, failed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, run.ID, run.StartedAt, run.FinishedAt, run.Open, run.Opened, run.Resolved, run.Failed)
+	return errors.Wrap(err, "failed to create integrity run")
+}
+
+// ListRuns returns the latest runs, newest first.
+func (r *IntegrityRepository) ListRuns(ctx context.Context, limit int) ([]domain.IntegrityRun, error) {
+	runs := []domain.IntegrityRun{}
+	err := r.db.SelectContext(ctx, &runs, `
+		SELECT id, started_at, finished_at, open_findings, opened, resolved, failed
+		FROM admin_schema.integrity_runs
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list integrity runs")
+	}
+	return runs, nil
+}
@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrityRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewIntegrityRepository(db)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	t.Run("a problem stays one finding while it is seen", func(t *testing.T) {
+		// A check of its own, so real findings are never resolved
+		check := domain.IntegrityCheck("test_" + uuid.NewString()[:8])
+		t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.integrity_findings WHERE check_name = $1`, check) })
+		a := domain.IntegrityFinding{Severity: domain.SecuritySeverityMedium, ResourceType: "wallet", ResourceID: "a", Detail: "first"}
+		b := domain.IntegrityFinding{Severity: domain.SecuritySeverityMedium, ResourceType: "wallet", ResourceID: "b"}
+
+		opened, resolved, err := repo.RecordFindings(ctx, check, []domain.IntegrityFinding{a, b}, now)
+		require.NoError(t, err)
+		assert.Equal(t, 2, opened)
+		assert.Equal(t, 0, resolved)
+
+		a.Severity, a.Detail = domain.SecuritySeverityHigh, "again"
+		opened, resolved, err = repo.RecordFindings(ctx, check, []domain.IntegrityFinding{a}, now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, opened, "a is still open")
+		assert.Equal(t, 1, resolved, "b was not seen")
+
+		open, total, err := repo.ListFindings(ctx, check, domain.IntegrityFindingOpen, 10, 0)
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		assert.Equal(t, "a", open[0].ResourceID)
+		assert.Equal(t, domain.SecuritySeverityHigh, open[0].Severity)
+		assert.Equal(t, "again", open[0].Detail)
+		assert.True(t, open[0].FirstSeenAt.Equal(now))
+		assert.True(t, open[0].LastSeenAt.Equal(now.Add(time.Hour)))
+
+		// b comes back after it was resolved
+		opened, _, err = repo.RecordFindings(ctx, check, []domain.IntegrityFinding{a, b}, now.Add(2*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, opened)
+		_, total, err = repo.ListFindings(ctx, check, "", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total, "the resolved finding is kept")
+	})
+
+	t.Run("completed transactions without ledger entries", func(t *testing.T) {
+		userID := testUser(t, db, now)
+		walletID := testWallet(t, db, userID, domain.MWK, domain.WalletStatusActive)
+		completed := testCredit(t, db, userID, walletID, 100, domain.TransactionStatusCompleted, "", now)
+		pending := testCredit(t, db, userID, walletID, 100, domain.TransactionStatusPending, "", now)
+
+		found, err := repo.TransactionsWithoutLedger(ctx, now.Add(time.Minute))
+		require.NoError(t, err)
+		ids := findingIDs(found)
+		assert.Contains(t, ids, completed.String())
+		assert.NotContains(t, ids, pending.String())
+
+		found, err = repo.TransactionsWithoutLedger(ctx, now)
+		require.NoError(t, err)
+		assert.NotContains(t, findingIDs(found), completed.String(), "still within the grace period")
+	})
+
+	t.Run("open wallets of deleted users", func(t *testing.T) {
+		// MW customers hold one MWK wallet each
+		deleted := func(status domain.WalletStatus, balance int64) uuid.UUID {
+			userID := testUser(t, db, now)
+			id := testWallet(t, db, userID, domain.MWK, status)
+			_, err := db.Exec(`UPDATE customer_schema.wallets SET ledger_balance = $2, available_balance = $2 WHERE id = $1`, id, balance)
+			require.NoError(t, err)
+			_, err = db.Exec(`UPDATE customer_schema.users SET user_status = 'deleted' WHERE id = $1`, userID)
+			require.NoError(t, err)
+			return id
+		}
+		funded := deleted(domain.WalletStatusActive, 10)
+		empty := deleted(domain.WalletStatusSuspended, 0)
+		closed := deleted(domain.WalletStatusClosed, 0)
+		kept := testWallet(t, db, testUser(t, db, now), domain.MWK, domain.WalletStatusActive)
+
+		found, err := repo.WalletsWithoutUsers(ctx)
+		require.NoError(t, err)
+		severity := map[string]string{}
+		for _, f := range found {
+			severity[f.ResourceID] = f.Severity
+		}
+		assert.Equal(t, domain.SecuritySeverityHigh, severity[funded.String()], "still holds money")
+		assert.Equal(t, domain.SecuritySeverityMedium, severity[empty.String()])
+		assert.NotContains(t, severity, closed.String())
+		assert.NotContains(t, severity, kept.String())
+	})
+
+	t.Run("settlements in flight for too long", func(t *testing.T) {
+		stale := testSettlement(t, db, 100, "submitted", map[string]interface{}{})
+		done := testSettlement(t, db, 100, "completed", map[string]interface{}{})
+		_, err := db.Exec(`UPDATE customer_schema.settlements SET updated_at = $3 WHERE id IN ($1, $2)`,
+			stale, done, now)
+		require.NoError(t, err)
+
+		found, err := repo.StalePending(ctx, now.Add(time.Minute))
+		require.NoError(t, err)
+		ids := findingIDs(found)
+		assert.Contains(t, ids, stale.String())
+		assert.NotContains(t, ids, done.String())
+	})
+}
+
+func findingIDs(found []domain.IntegrityFinding) []string {
+	ids := make([]string, len(found))
+	for i, f := range found {
+		ids[i] = f.ResourceID
+	}
+	return ids
+}
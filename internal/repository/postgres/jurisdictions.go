@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// JurisdictionRepository keeps the restricted jurisdictions list and its
+// change log.
+type JurisdictionRepository struct {
+	db *sqlx.DB
+}
+
+func NewJurisdictionRepository(db *sqlx.DB) *JurisdictionRepository {
+	return &JurisdictionRepository{db: db}
+}
+
+const restrictedJurisdictionColumns = `
+	id, country_code, scope, reason, authority, effective_from, effective_until,
+	created_by, updated_by, created_at, updated_at`
+
+// Create saves a restriction and its change log entry in one transaction.
+func (r *JurisdictionRepository) Create(ctx context.Context, j *domain.RestrictedJurisdiction, c *domain.JurisdictionChange) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExecContext(ctx, `
+		INSERT INTO admin_schema.restricted_jurisdictions (`+restrictedJurisdictionColumns+`)
+		VALUES (
+			:id, :country_code, :scope, :reason, :authority, :effective_from, :effective_until,
+			:created_by, :updated_by, :created_at, :updated_at)
+	`, j)
+	if err != nil {
+		return errors.Wrap(err, "failed to create restricted jurisdiction")
+	}
+	if err := insertJurisdictionChange(ctx, tx, c); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit restricted jurisdiction")
+	}
+	return nil
+}
+
+// Update saves j and its change log entry in one transaction, unless j was
+// updated since prev, in which case it reports false and changes nothing.
+func (r *JurisdictionRepository) Update(ctx context.Context, j *domain.RestrictedJurisdiction, prev time.Time, c *domain.JurisdictionChange) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_schema.restricted_jurisdictions
+		SET country_code = $1, scope = $2, reason = $3, authority = $4,
+			effective_from = $5, effective_until = $6, updated_by = $7, updated_at = $8
+		WHERE id = $9 AND updated_at = $10
+	`, j.CountryCode, j.Scope, j.Reason, j.Authority, j.EffectiveFrom, j.EffectiveUntil,
+		j.UpdatedBy, j.UpdatedAt, j.ID, prev)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update restricted jurisdiction")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := insertJurisdictionChange(ctx, tx, c); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit restricted jurisdiction")
+	}
+	return true, nil
+}
+
+// Delete removes a restriction and logs it in one transaction, unless it
+// was updated since prev.
+func (r *JurisdictionRepository) Delete(ctx context.Context, id uuid.UUID, prev time.Time, c *domain.JurisdictionChange) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM admin_schema.restricted_jurisdictions WHERE id = $1 AND updated_at = $2
+	`, id, prev)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete restricted jurisdiction")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := insertJurisdictionChange(ctx, tx, c); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit restricted jurisdiction")
+	}
+	return true, nil
+}
+
+func insertJurisdictionChange(ctx context.Context, tx *sqlx.Tx, c *domain.JurisdictionChange) error {
+	_, err := tx.NamedExecContext(ctx, `
+		INSERT INTO admin_schema.restricted_jurisdiction_changes
+			(id, jurisdiction_id, country_code, action, before, after, note, changed_by, changed_at)
+		VALUES (:id, :jurisdiction_id, :country_code, :action, :before, :after, :note, :changed_by, :changed_at)
+	`, c)
+	if err != nil {
+		return errors.Wrap(err, "failed to record restricted jurisdiction change")
+	}
+	return nil
+}
+
+// Get returns nil when there is no such restriction.
+func (r *JurisdictionRepository) Get(ctx context.Context, id uuid.UUID) (*domain.RestrictedJurisdiction, error) {
+	var j domain.RestrictedJurisdiction
+	err := r.db.GetContext(ctx, &j, `SELECT`+restrictedJurisdictionColumns+` FROM admin_schema.restricted_jurisdictions WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get restricted jurisdiction")
+	}
+	return &j, nil
+}
+
+func (r *JurisdictionRepository) List(ctx context.Context, country string) ([]domain.RestrictedJurisdiction, error) {
+	items := []domain.RestrictedJurisdiction{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT`+restrictedJurisdictionColumns+`
+		FROM admin_schema.restricted_jurisdictions
+		WHERE ($1 = '' OR country_code = $1)
+		ORDER BY country_code, effective_from
+	`, country)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list restricted jurisdictions")
+	}
+	return items, nil
+}
+
+// Current returns the restrictions in force or scheduled at now.
+func (r *JurisdictionRepository) Current(ctx context.Context, now time.Time) ([]domain.RestrictedJurisdiction, error) {
+	items := []domain.RestrictedJurisdiction{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT`+restrictedJurisdictionColumns+`
+		FROM admin_schema.restricted_jurisdictions
+		WHERE effective_until IS NULL OR effective_until > $1
+		ORDER BY country_code, effective_from
+	`, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load current restricted jurisdictions")
+	}
+	return items, nil
+}
+
+// Changes returns the change log, newest first.
+func (r *JurisdictionRepository) Changes(ctx context.Context, f domain.JurisdictionChangeFilter, limit, offset int) ([]domain.JurisdictionChange, int, error) {
+	var from, to *time.Time
+	if !f.From.IsZero() {
+		from = &f.From
+	}
+	if !f.To.IsZero() {
+		to = &f.To
+	}
+	where := `WHERE ($1 = '' OR country_code = $1)
+		AND ($2::timestamptz IS NULL OR changed_at >= $2)
+		AND ($3::timestamptz IS NULL OR changed_at < $3)`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.restricted_jurisdiction_changes `+where, f.CountryCode, from, to); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count restricted jurisdiction changes")
+	}
+	changes := []domain.JurisdictionChange{}
+	err := r.db.SelectContext(ctx, &changes, `
+		SELECT id, jurisdiction_id, country_code, action, before, after, note, changed_by, changed_at
+		FROM admin_schema.restricted_jurisdiction_changes `+where+`
+		ORDER BY changed_at DESC, id
+		LIMIT $4 OFFSET $5
+	`, f.CountryCode, from, to, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list restricted jurisdiction changes")
+	}
+	return changes, total, nil
+}
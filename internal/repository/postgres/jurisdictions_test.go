@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCountry is reserved for user assignment in ISO 3166, so no real
+// restriction is ever listed with these.
+const testCountry = "QZ"
+
+func TestJurisdictionRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewJurisdictionRepository(db)
+	// Long ended, so nothing here is in force for real payments
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+	admin := uuid.New()
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM admin_schema.restricted_jurisdiction_changes WHERE changed_by = $1`, admin)
+		db.Exec(`DELETE FROM admin_schema.restricted_jurisdictions WHERE created_by = $1`, admin)
+	})
+
+	ended := testJurisdiction(t, db, repo, admin, domain.JurisdictionInbound, now.Add(-48*time.Hour), now)
+	inForce := testJurisdiction(t, db, repo, admin, domain.JurisdictionOutbound, now.Add(-time.Hour), now.Add(time.Hour))
+	scheduled := testJurisdiction(t, db, repo, admin, domain.JurisdictionInbound, now.Add(24*time.Hour), now.Add(48*time.Hour))
+
+	t.Run("lists by effective date", func(t *testing.T) {
+		list, err := repo.List(ctx, testCountry)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{ended.ID, inForce.ID, scheduled.ID}, jurisdictionIDs(list))
+
+		current, err := repo.Current(ctx, now)
+		require.NoError(t, err)
+		ids := jurisdictionIDs(current)
+		assert.NotContains(t, ids, ended.ID, "ended at now")
+		assert.Contains(t, ids, inForce.ID)
+		assert.Contains(t, ids, scheduled.ID)
+
+		got, err := repo.Get(ctx, inForce.ID)
+		require.NoError(t, err)
+		assert.True(t, got.EffectiveFrom.Equal(inForce.EffectiveFrom))
+		assert.True(t, got.EffectiveUntil.Equal(*inForce.EffectiveUntil))
+		missing, err := repo.Get(ctx, uuid.New())
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("updates only from what was read", func(t *testing.T) {
+		before := *scheduled
+		j := *scheduled
+		j.Scope, j.UpdatedAt = domain.JurisdictionBoth, now.Add(time.Minute)
+		c := jurisdictionChange(&j, domain.JurisdictionUpdated, &before, &j, now.Add(time.Minute))
+
+		ok, err := repo.Update(ctx, &j, before.UpdatedAt.Add(-time.Second), c)
+		require.NoError(t, err)
+		assert.False(t, ok, "changed by another admin since")
+		ok, err = repo.Update(ctx, &j, before.UpdatedAt, c)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		got, err := repo.Get(ctx, j.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.JurisdictionBoth, got.Scope)
+
+		deleted := jurisdictionChange(&j, domain.JurisdictionDeleted, &j, nil, now.Add(2*time.Minute))
+		ok, err = repo.Delete(ctx, j.ID, before.UpdatedAt, deleted)
+		require.NoError(t, err)
+		assert.False(t, ok, "updated since")
+		ok, err = repo.Delete(ctx, j.ID, j.UpdatedAt, deleted)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		got, err = repo.Get(ctx, j.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("the change log keeps every change", func(t *testing.T) {
+		f := domain.JurisdictionChangeFilter{CountryCode: testCountry, From: now.Add(-24 * time.Hour), To: now.Add(time.Hour)}
+		changes, total, err := repo.Changes(ctx, f, 10, 0)
+		require.NoError(t, err)
+		require.Equal(t, 5, total, "three created, then one updated and deleted; refused writes are not logged")
+		assert.Equal(t, domain.JurisdictionDeleted, changes[0].Action, "newest first")
+		assert.Nil(t, changes[0].After)
+		require.NotNil(t, changes[0].Before)
+		assert.Equal(t, domain.JurisdictionBoth, changes[0].Before.Scope)
+		assert.Equal(t, domain.JurisdictionUpdated, changes[1].Action)
+		assert.Equal(t, domain.JurisdictionInbound, changes[1].Before.Scope)
+		assert.Equal(t, domain.JurisdictionBoth, changes[1].After.Scope)
+
+		f.From = now.Add(time.Minute)
+		_, total, err = repo.Changes(ctx, f, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+		page, _, err := repo.Changes(ctx, f, 1, 1)
+		require.NoError(t, err)
+		require.Len(t, page, 1)
+		assert.Equal(t, domain.JurisdictionUpdated, page[0].Action)
+	})
+}
+
+// testJurisdiction restricts testCountry in scope from from until until,
+// as created by admin early on the test day. The caller removes it, with
+// its change log, by admin.
+func testJurisdiction(t *testing.T, db *sqlx.DB, repo *JurisdictionRepository, admin uuid.UUID, scope domain.JurisdictionScope, from, until time.Time) *domain.RestrictedJurisdiction {
+	t.Helper()
+	created := time.Date(1990, 3, 2, 0, 0, 0, 0, time.UTC)
+	j := &domain.RestrictedJurisdiction{
+		ID:             uuid.New(),
+		CountryCode:    testCountry,
+		Scope:          scope,
+		Reason:         "test",
+		EffectiveFrom:  from,
+		EffectiveUntil: &until,
+		CreatedBy:      admin,
+		UpdatedBy:      admin,
+		CreatedAt:      created,
+		UpdatedAt:      created,
+	}
+	require.NoError(t, repo.Create(context.Background(), j, jurisdictionChange(j, domain.JurisdictionCreated, nil, j, created)))
+	return j
+}
+
+func jurisdictionChange(j *domain.RestrictedJurisdiction, action domain.JurisdictionAction, before, after *domain.RestrictedJurisdiction, at time.Time) *domain.JurisdictionChange {
+	return &domain.JurisdictionChange{
+		ID:             uuid.New(),
+		JurisdictionID: j.ID,
+		CountryCode:    j.CountryCode,
+		Action:         action,
+		Before:         before,
+		After:          after,
+		Note:           "test",
+		ChangedBy:      j.CreatedBy,
+		ChangedAt:      at,
+	}
+}
+
+func jurisdictionIDs(items []domain.RestrictedJurisdiction) []uuid.UUID {
+	ids := make([]uuid.UUID, len(items))
+	for i, j := range items {
+		ids[i] = j.ID
+	}
+	return ids
+}
@@ -25,9 +25,10 @@ func (r *KYCRepository) Create(ctx context.Context, doc *domain.KYCDocument) err
 		INSERT INTO customer_schema.kyc_documents (
 			id, user_id, document_type, document_number, issuing_country,
 			issue_date, expiry_date, front_image_url, back_image_url, selfie_image_url,
-			verification_status, metadata, created_at, updated_at
+			verification_status, metadata, created_at, updated_at,
+			review_status, replaces_id, virus_scan_status
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
 		)
 	`
 
@@ -35,6 +36,7 @@ func (r *KYCRepository) Create(ctx context.Context, doc *domain.KYCDocument) err
 		doc.ID, doc.UserID, doc.DocumentType, doc.DocumentNumber, doc.IssuingCountry,
 		doc.IssueDate, doc.ExpiryDate, doc.FrontImageURL, doc.BackImageURL, doc.SelfieImageURL,
 		doc.VerificationStatus, doc.Metadata, doc.CreatedAt, doc.UpdatedAt,
+		reviewStatus(doc), doc.ReplacesID, scanStatus(doc),
 	)
 
 	if err != nil {
@@ -44,6 +46,65 @@ func (r *KYCRepository) Create(ctx context.Context, doc *domain.KYCDocument) err
 	return nil
 }
 
+func reviewStatus(doc *domain.KYCDocument) domain.KYCDocumentStatus {
+	if doc.ReviewStatus == "" {
+		return domain.KYCDocumentUploaded
+	}
+	return doc.ReviewStatus
+}
+
+func scanStatus(doc *domain.KYCDocument) domain.VirusScanStatus {
+	if doc.VirusScanStatus == "" {
+		return domain.VirusScanSkipped
+	}
+	return doc.VirusScanStatus
+}
+
+// Resubmit stores doc as a new attempt at the document it replaces and marks
+// the old attempt superseded, in one transaction. It fails if the old
+// attempt was already superseded.
+func (r *KYCRepository) Resubmit(ctx context.Context, doc *domain.KYCDocument) error {
+	if doc.ReplacesID == nil {
+		return errors.New("resubmitted kyc document must replace an attempt")
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.kyc_documents
+		SET superseded_at = $1, updated_at = $1
+		WHERE id = $2 AND user_id = $3 AND superseded_at IS NULL
+	`, doc.CreatedAt, *doc.ReplacesID, doc.UserID)
+	if err != nil {
+		return errors.Wrap(err, "failed to supersede kyc document")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("kyc document already replaced")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.kyc_documents (
+			id, user_id, document_type, document_number, issuing_country,
+			issue_date, expiry_date, front_image_url, back_image_url, selfie_image_url,
+			verification_status, metadata, created_at, updated_at,
+			review_status, replaces_id, virus_scan_status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+	`,
+		doc.ID, doc.UserID, doc.DocumentType, doc.DocumentNumber, doc.IssuingCountry,
+		doc.IssueDate, doc.ExpiryDate, doc.FrontImageURL, doc.BackImageURL, doc.SelfieImageURL,
+		doc.VerificationStatus, doc.Metadata, doc.CreatedAt, doc.UpdatedAt,
+		reviewStatus(doc), doc.ReplacesID, scanStatus(doc),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kyc document")
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit kyc resubmission")
+}
+
 func (r *KYCRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.KYCDocument, error) {
 	query := `
 		SELECT * FROM customer_schema.kyc_documents
@@ -93,3 +154,38 @@ func (r *KYCRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status s
 
 	return nil
 }
+
+// SetReviewStatus moves a document through review. reason is kept only for
+// rejections and requests for more information.
+func (r *KYCRepository) SetReviewStatus(ctx context.Context, id uuid.UUID, status domain.KYCDocumentStatus, reason *string) error {
+	if status != domain.KYCDocumentRejected && status != domain.KYCDocumentInfoRequested {
+		reason = nil
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.kyc_documents
+		SET review_status = $1, rejection_reason = $2, updated_at = $3
+		WHERE id = $4
+	`, status, reason, time.Now(), id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update kyc review status")
+	}
+	return nil
+}
+
+// SetVirusScan records the outcome of scanning a document's files.
+// signature is kept only for infected documents.
+func (r *KYCRepository) SetVirusScan(ctx context.Context, id uuid.UUID, status domain.VirusScanStatus, signature *string) error {
+	if status != domain.VirusScanInfected {
+		signature = nil
+	}
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.kyc_documents
+		SET virus_scan_status = $1, virus_signature = $2, scanned_at = $3, updated_at = $3
+		WHERE id = $4
+	`, status, signature, now, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update kyc virus scan")
+	}
+	return nil
+}
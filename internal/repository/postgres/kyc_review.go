@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+// kycQueue groups users with undecided KYC applications by their current
+// documents; a queue's HAVING clause picks which profiles it holds.
+const kycQueue = `
+	SELECT d.user_id, u.kyc_status, COUNT(*) AS documents, MIN(d.created_at) AS waiting_since
+	FROM customer_schema.kyc_documents d
+	JOIN customer_schema.users u ON u.id = d.user_id
+	WHERE d.superseded_at IS NULL AND u.kyc_status IN ('pending', 'processing')
+	GROUP BY d.user_id, u.kyc_status
+`
+
+// awaitingScan counts a profile's documents that have not been through the
+// virus scan yet.
+const awaitingScan = `COUNT(*) FILTER (WHERE d.review_status IN ('uploaded', 'scanning', 'scanned_clean'))`
+
+// FindPendingReview returns profiles a reviewer can decide now: at least
+// one document is in review and none still waits for its virus scan.
+// Profiles that have waited longest come first.
+func (r *KYCRepository) FindPendingReview(ctx context.Context, limit, offset int) ([]domain.KYCQueueEntry, int, error) {
+	return r.findQueue(ctx, `HAVING COUNT(*) FILTER (WHERE d.review_status = 'in_review') > 0 AND `+awaitingScan+` = 0`, limit, offset)
+}
+
+// FindPendingVerification returns profiles with documents still waiting
+// for their virus scan, including scans that failed and need a rescan.
+func (r *KYCRepository) FindPendingVerification(ctx context.Context, limit, offset int) ([]domain.KYCQueueEntry, int, error) {
+	return r.findQueue(ctx, `HAVING `+awaitingScan+` > 0`, limit, offset)
+}
+
+func (r *KYCRepository) findQueue(ctx context.Context, having string, limit, offset int) ([]domain.KYCQueueEntry, int, error) {
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM (`+kycQueue+having+`) q`); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count kyc queue")
+	}
+	entries := []domain.KYCQueueEntry{}
+	err := r.db.SelectContext(ctx, &entries, kycQueue+having+`
+		ORDER BY waiting_since, d.user_id
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list kyc queue")
+	}
+	return entries, total, nil
+}
+
+// CreateReview records a reviewer's decision on a KYC profile.
+func (r *KYCRepository) CreateReview(ctx context.Context, rv *domain.KYCReview) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.kyc_reviews (id, user_id, reviewer_id, decision, notes, reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, rv.ID, rv.UserID, rv.ReviewerID, rv.Decision, rv.Notes, rv.ReviewedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kyc review")
+	}
+	return nil
+}
+
+// ListReviews returns the decisions taken on a user's KYC profile, newest
+// first.
+func (r *KYCRepository) ListReviews(ctx context.Context, userID uuid.UUID) ([]domain.KYCReview, error) {
+	reviews := []domain.KYCReview{}
+	err := r.db.SelectContext(ctx, &reviews, `
+		SELECT id, user_id, reviewer_id, decision, notes, reviewed_at
+		FROM customer_schema.kyc_reviews
+		WHERE user_id = $1
+		ORDER BY reviewed_at DESC
+	`, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list kyc reviews")
+	}
+	return reviews, nil
+}
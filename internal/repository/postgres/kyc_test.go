@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKYCRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewKYCRepository(db)
+	submitted := time.Date(1990, 3, 1, 9, 0, 0, 0, time.UTC)
+	user := testUser(t, db, submitted)
+
+	first := testKYCDocument(user, submitted)
+	require.NoError(t, repo.Create(ctx, first))
+	reason := "photo is blurred"
+	require.NoError(t, repo.SetReviewStatus(ctx, first.ID, domain.KYCDocumentRejected, &reason))
+	signature := "Eicar-Test-Signature"
+	require.NoError(t, repo.SetVirusScan(ctx, first.ID, domain.VirusScanClean, &signature))
+
+	got, err := repo.GetByID(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.KYCDocumentRejected, got.ReviewStatus)
+	require.NotNil(t, got.RejectionReason)
+	assert.Equal(t, reason, *got.RejectionReason)
+	assert.Equal(t, domain.VirusScanClean, got.VirusScanStatus)
+	assert.Nil(t, got.VirusSignature, "only infected documents keep a signature")
+	assert.NotNil(t, got.ScannedAt)
+
+	t.Run("a resubmission supersedes the attempt once", func(t *testing.T) {
+		second := testKYCDocument(user, submitted.Add(time.Hour))
+		second.ReplacesID = &first.ID
+		require.NoError(t, repo.Resubmit(ctx, second))
+		again := testKYCDocument(user, submitted.Add(2*time.Hour))
+		again.ReplacesID = &first.ID
+		assert.Error(t, repo.Resubmit(ctx, again), "already replaced")
+
+		docs, err := repo.GetByUserID(ctx, user)
+		require.NoError(t, err)
+		require.Len(t, docs, 2, "nothing of the refused attempt is stored")
+		assert.Equal(t, second.ID, docs[0].ID, "newest first")
+		assert.Nil(t, docs[0].SupersededAt)
+		assert.Equal(t, first.ID, *docs[0].ReplacesID)
+		require.NotNil(t, docs[1].SupersededAt)
+		assert.True(t, docs[1].SupersededAt.Equal(second.CreatedAt))
+		assert.Equal(t, domain.KYCDocumentRejected, docs[1].ReviewStatus, "the old attempt keeps its outcome")
+
+		require.NoError(t, repo.SetReviewStatus(ctx, second.ID, domain.KYCDocumentAccepted, &reason))
+		require.NoError(t, repo.UpdateStatus(ctx, second.ID, string(domain.KYCStatusVerified), &reason, &user))
+		require.NoError(t, repo.SetVirusScan(ctx, second.ID, domain.VirusScanInfected, &signature))
+		got, err := repo.GetByID(ctx, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.KYCDocumentAccepted, got.ReviewStatus)
+		assert.Nil(t, got.RejectionReason, "only rejections keep a reason")
+		assert.Equal(t, string(domain.KYCStatusVerified), got.VerificationStatus)
+		assert.NotNil(t, got.VerifiedAt)
+		require.NotNil(t, got.VirusSignature)
+		assert.Equal(t, signature, *got.VirusSignature)
+	})
+
+	_, err = repo.GetByID(ctx, uuid.New())
+	assert.Error(t, err)
+}
+
+// testKYCDocument is an unsaved passport of user's, submitted at
+// submitted. The documents go with the user.
+func testKYCDocument(user uuid.UUID, submitted time.Time) *domain.KYCDocument {
+	number, country := "P1", "MW"
+	return &domain.KYCDocument{
+		ID:                 uuid.New(),
+		UserID:             user,
+		DocumentType:       "passport",
+		DocumentNumber:     &number,
+		IssuingCountry:     &country,
+		VerificationStatus: string(domain.KYCStatusPending),
+		ReviewStatus:       domain.KYCDocumentInReview,
+		VirusScanStatus:    domain.VirusScanPending,
+		CreatedAt:          submitted,
+		UpdatedAt:          submitted,
+	}
+}
@@ -12,17 +12,18 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
 // LedgerRepository implements ledger persistence with hash chaining.
 type LedgerRepository struct {
-	db *sqlx.DB
+	db *instrumentedDB
 }
 
 // NewLedgerRepository creates a new LedgerRepository.
 func NewLedgerRepository(db *sqlx.DB) *LedgerRepository {
-	return &LedgerRepository{db: db}
+	return &LedgerRepository{db: instrument(db)}
 }
 
 // CreateEntry adds a new entry to the ledger with hash chaining.
@@ -142,6 +143,56 @@ func (r *LedgerRepository) CreateEntryTx(ctx context.Context, tx *sqlx.Tx, txID
 	return nil
 }
 
+// LedgerEvent is one event to append to the transaction ledger.
+type LedgerEvent struct {
+	TransactionID uuid.UUID
+	EventType     string
+	Amount        decimal.Decimal
+	Currency      domain.Currency
+	Status        string
+}
+
+// CreateEntriesTx appends events to the ledger in order using an existing
+// transaction. The chain head is locked once and the entries are chained in
+// memory and copied in together, each a microsecond after the previous one
+// so the chain order survives ordering by created_at.
+func (r *LedgerRepository) CreateEntriesTx(ctx context.Context, tx *sqlx.Tx, events []LedgerEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var previousHash string
+	queryLast := `SELECT hash FROM customer_schema.transaction_ledger ORDER BY created_at DESC LIMIT 1 FOR UPDATE`
+	if err := tx.GetContext(ctx, &previousHash, queryLast); err != nil {
+		previousHash = "0000000000000000000000000000000000000000000000000000000000000000"
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema("customer_schema", "transaction_ledger",
+		"id", "transaction_id", "event_type", "amount", "currency", "status", "previous_hash", "hash", "created_at",
+	))
+	if err != nil {
+		return pkgerrors.Wrap(err, "failed to prepare ledger copy")
+	}
+	defer stmt.Close()
+
+	base := time.Now().UTC().Truncate(time.Microsecond)
+	for i, e := range events {
+		now := base.Add(time.Duration(i) * time.Microsecond)
+		data := fmt.Sprintf("%s:%s:%s:%s:%s:%s:%d",
+			e.TransactionID.String(), e.EventType, e.Amount.String(), e.Currency, e.Status, previousHash, now.UnixNano())
+		hashBytes := sha256.Sum256([]byte(data))
+		hash := hex.EncodeToString(hashBytes[:])
+
+		if _, err := stmt.ExecContext(ctx, uuid.New(), e.TransactionID, e.EventType, e.Amount, string(e.Currency), e.Status, previousHash, hash, now); err != nil {
+			return pkgerrors.Wrap(err, "failed to copy ledger entry")
+		}
+		previousHash = hash
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return pkgerrors.Wrap(err, "failed to copy ledger entries")
+	}
+	return nil
+}
+
 // VerifyChain verifies the integrity of the ledger chain.
 // Returns true if valid, false and error details if invalid.
 func (r *LedgerRepository) VerifyChain(ctx context.Context) (bool, error) {
@@ -177,3 +228,22 @@ func (r *LedgerRepository) VerifyChain(ctx context.Context) (bool, error) {
 
 	return true, nil
 }
+
+// TrialBalance sums the journal lines posted up to asOf by account and
+// currency, in chart order.
+func (r *LedgerRepository) TrialBalance(ctx context.Context, asOf time.Time) ([]domain.TrialBalanceLine, error) {
+	lines := []domain.TrialBalanceLine{}
+	err := r.db.SelectContext(ctx, &lines, `
+		SELECT a.code AS account_code, a.name AS account_name, a.account_type,
+			l.currency, SUM(l.debit) AS debit, SUM(l.credit) AS credit
+		FROM customer_schema.journal_lines l
+		JOIN customer_schema.ledger_accounts a ON a.code = l.account_code
+		WHERE l.created_at <= $1
+		GROUP BY a.code, a.name, a.account_type, l.currency
+		ORDER BY a.code, l.currency
+	`, asOf)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to compute trial balance")
+	}
+	return lines, nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/invoice"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const merchantInvoiceColumns = `
+	id, merchant_id, wallet_id, payer_id, reference, amount, currency, memo, status, token,
+	expires_at, transaction_id, paid_by, created_at, sent_at, paid_at, cancelled_at, updated_at
+`
+
+// MerchantInvoiceRepository stores merchants' invoices.
+type MerchantInvoiceRepository struct {
+	db *sqlx.DB
+}
+
+func NewMerchantInvoiceRepository(db *sqlx.DB) *MerchantInvoiceRepository {
+	return &MerchantInvoiceRepository{db: db}
+}
+
+func (r *MerchantInvoiceRepository) Create(ctx context.Context, inv *domain.MerchantInvoice) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.merchant_invoices (`+merchantInvoiceColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)
+	`, inv.ID, inv.MerchantID, inv.WalletID, inv.PayerID, inv.Reference, inv.Amount, inv.Currency, inv.Memo, inv.Status, inv.Token,
+		inv.ExpiresAt, inv.TransactionID, inv.PaidBy, inv.CreatedAt, inv.SentAt, inv.PaidAt, inv.CancelledAt, inv.UpdatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "idx_merchant_invoices_reference" { // unique_violation
+		return invoice.ErrReferenceExists
+	}
+	return errors.Wrap(err, "failed to create invoice")
+}
+
+func (r *MerchantInvoiceRepository) Get(ctx context.Context, id uuid.UUID) (*domain.MerchantInvoice, error) {
+	return r.getBy(ctx, "id", id)
+}
+
+func (r *MerchantInvoiceRepository) GetByToken(ctx context.Context, token string) (*domain.MerchantInvoice, error) {
+	return r.getBy(ctx, "token", token)
+}
+
+func (r *MerchantInvoiceRepository) getBy(ctx context.Context, column string, value interface{}) (*domain.MerchantInvoice, error) {
+	var inv domain.MerchantInvoice
+	err := r.db.GetContext(ctx, &inv, `SELECT `+merchantInvoiceColumns+` FROM customer_schema.merchant_invoices WHERE `+column+` = $1`, value)
+	if err == sql.ErrNoRows {
+		return nil, invoice.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invoice")
+	}
+	return &inv, nil
+}
+
+func (r *MerchantInvoiceRepository) List(ctx context.Context, merchantID uuid.UUID, status domain.MerchantInvoiceStatus, limit, offset int) ([]domain.MerchantInvoice, int, error) {
+	where := "WHERE merchant_id = $1"
+	args := []interface{}{merchantID}
+	if status != "" {
+		where += " AND status = $2"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.merchant_invoices `+where, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count invoices")
+	}
+
+	items := []domain.MerchantInvoice{}
+	query := fmt.Sprintf(`SELECT %s FROM customer_schema.merchant_invoices %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		merchantInvoiceColumns, where, len(args)+1, len(args)+2)
+	if err := r.db.SelectContext(ctx, &items, query, append(args, limit, offset)...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list invoices")
+	}
+	return items, total, nil
+}
+
+func (r *MerchantInvoiceRepository) Transition(ctx context.Context, inv *domain.MerchantInvoice, from domain.MerchantInvoiceStatus) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.merchant_invoices
+		SET status = $3, transaction_id = $4, paid_by = $5, sent_at = $6, paid_at = $7, cancelled_at = $8, updated_at = $9
+		WHERE id = $1 AND status = $2
+	`, inv.ID, from, inv.Status, inv.TransactionID, inv.PaidBy, inv.SentAt, inv.PaidAt, inv.CancelledAt, inv.UpdatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update invoice")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update invoice")
+	}
+	return n > 0, nil
+}
+
+func (r *MerchantInvoiceRepository) ExpireDue(ctx context.Context, now time.Time, limit int) ([]domain.MerchantInvoice, error) {
+	var items []domain.MerchantInvoice
+	query := `
+		UPDATE customer_schema.merchant_invoices
+		SET status = $1, updated_at = $3
+		WHERE id IN (
+			SELECT id FROM customer_schema.merchant_invoices
+			WHERE status = $2 AND expires_at <= $3
+			ORDER BY expires_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + merchantInvoiceColumns
+	if err := r.db.SelectContext(ctx, &items, query, domain.MerchantInvoiceExpired, domain.MerchantInvoiceSent, now, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to expire invoices")
+	}
+	return items, nil
+}
@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/invoice"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerchantInvoiceRepository_Invoices(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewMerchantInvoiceRepository(db)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+	merchantID := testUser(t, db, now)
+	walletID := testWallet(t, db, merchantID, domain.MWK, domain.WalletStatusActive)
+
+	t.Run("references are unique per merchant", func(t *testing.T) {
+		testInvoice(t, db, repo, merchantID, walletID, "INV-1", domain.MerchantInvoiceDraft, now)
+		dup := newTestInvoice(merchantID, walletID, "INV-1", domain.MerchantInvoiceDraft, now)
+		assert.ErrorIs(t, repo.Create(ctx, dup), invoice.ErrReferenceExists)
+
+		// Invoices without a reference do not clash
+		testInvoice(t, db, repo, merchantID, walletID, "", domain.MerchantInvoiceDraft, now)
+		testInvoice(t, db, repo, merchantID, walletID, "", domain.MerchantInvoiceDraft, now)
+
+		otherID := testUser(t, db, now)
+		otherWallet := testWallet(t, db, otherID, domain.MWK, domain.WalletStatusActive)
+		testInvoice(t, db, repo, otherID, otherWallet, "INV-1", domain.MerchantInvoiceDraft, now)
+	})
+
+	t.Run("transition is conditional on the status", func(t *testing.T) {
+		inv := testInvoice(t, db, repo, merchantID, walletID, "", domain.MerchantInvoiceSent, now)
+		cancelled := *inv
+		cancelled.Status, cancelled.CancelledAt = domain.MerchantInvoiceCancelled, &now
+		ok, err := repo.Transition(ctx, &cancelled, domain.MerchantInvoiceSent)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		paid := *inv
+		paid.Status, paid.PaidAt = domain.MerchantInvoicePaid, &now
+		ok, err = repo.Transition(ctx, &paid, domain.MerchantInvoiceSent)
+		require.NoError(t, err)
+		assert.False(t, ok, "cancelled in the meantime")
+
+		got, err := repo.GetByToken(ctx, inv.Token)
+		require.NoError(t, err)
+		assert.Equal(t, domain.MerchantInvoiceCancelled, got.Status)
+		_, err = repo.GetByToken(ctx, "no-such-token")
+		assert.ErrorIs(t, err, invoice.ErrNotFound)
+	})
+
+	t.Run("only sent invoices expire", func(t *testing.T) {
+		due := testInvoice(t, db, repo, merchantID, walletID, "", domain.MerchantInvoiceSent, now.Add(-time.Hour))
+		testInvoice(t, db, repo, merchantID, walletID, "", domain.MerchantInvoiceDraft, now.Add(-time.Hour))
+		notYet := testInvoice(t, db, repo, merchantID, walletID, "", domain.MerchantInvoiceSent, now.Add(time.Hour))
+
+		expired, err := repo.ExpireDue(ctx, now, 100)
+		require.NoError(t, err)
+		ids := invoiceIDs(expired)
+		assert.Contains(t, ids, due.ID)
+		assert.NotContains(t, ids, notYet.ID)
+
+		got, err := repo.Get(ctx, due.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.MerchantInvoiceExpired, got.Status)
+		expired, err = repo.ExpireDue(ctx, now, 100)
+		require.NoError(t, err)
+		assert.NotContains(t, invoiceIDs(expired), due.ID, "expired once")
+
+		items, total, err := repo.List(ctx, merchantID, domain.MerchantInvoiceExpired, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, []uuid.UUID{due.ID}, invoiceIDs(items))
+		_, total, err = repo.List(ctx, merchantID, "", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+	})
+}
+
+func newTestInvoice(merchantID, walletID uuid.UUID, reference string, status domain.MerchantInvoiceStatus, expiresAt time.Time) *domain.MerchantInvoice {
+	return &domain.MerchantInvoice{
+		ID:         uuid.New(),
+		MerchantID: merchantID,
+		WalletID:   walletID,
+		Reference:  reference,
+		Amount:     decimal.NewFromInt(25000),
+		Currency:   domain.MWK,
+		Status:     status,
+		Token:      uuid.NewString(),
+		ExpiresAt:  expiresAt,
+		CreatedAt:  expiresAt.Add(-24 * time.Hour),
+		UpdatedAt:  expiresAt.Add(-24 * time.Hour),
+	}
+}
+
+// testInvoice issues an invoice expiring at expiresAt and removes it after
+// the test.
+func testInvoice(t *testing.T, db *sqlx.DB, repo *MerchantInvoiceRepository, merchantID, walletID uuid.UUID, reference string, status domain.MerchantInvoiceStatus, expiresAt time.Time) *domain.MerchantInvoice {
+	t.Helper()
+	inv := newTestInvoice(merchantID, walletID, reference, status, expiresAt)
+	require.NoError(t, repo.Create(context.Background(), inv))
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.merchant_invoices WHERE id = $1`, inv.ID) })
+	return inv
+}
+
+func invoiceIDs(items []domain.MerchantInvoice) []uuid.UUID {
+	ids := make([]uuid.UUID, len(items))
+	for i, inv := range items {
+		ids[i] = inv.ID
+	}
+	return ids
+}
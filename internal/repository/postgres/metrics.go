@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kyd/internal/monitoring"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// MetricRepository computes hourly business metrics for anomaly detection.
+type MetricRepository struct {
+	db *sqlx.DB
+}
+
+func NewMetricRepository(db *sqlx.DB) *MetricRepository {
+	return &MetricRepository{db: db}
+}
+
+// Payment corridors are keyed by source and destination currency; settlement
+// latency is grouped by network.
+var metricQueries = map[monitoring.Metric]string{
+	monitoring.MetricVolume: `
+		SELECT currency || '-' || converted_currency AS corridor,
+			date_trunc('hour', created_at) AS hour,
+			COUNT(*)::float8 AS value,
+			COUNT(*) AS count
+		FROM customer_schema.transactions
+		WHERE transaction_type IN ` + sendingTypes + `
+		  AND created_at >= $1 AND created_at < $2
+		GROUP BY 1, 2
+	`,
+	monitoring.MetricDeclineRate: `
+		SELECT currency || '-' || converted_currency AS corridor,
+			date_trunc('hour', created_at) AS hour,
+			(COUNT(*) FILTER (WHERE status = 'failed'))::float8 / COUNT(*) AS value,
+			COUNT(*) AS count
+		FROM customer_schema.transactions
+		WHERE transaction_type IN ` + sendingTypes + `
+		  AND created_at >= $1 AND created_at < $2
+		GROUP BY 1, 2
+	`,
+	monitoring.MetricSettlementLatency: `
+		SELECT network AS corridor,
+			date_trunc('hour', completed_at) AS hour,
+			AVG(EXTRACT(EPOCH FROM completed_at - created_at))::float8 AS value,
+			COUNT(*) AS count
+		FROM customer_schema.settlements
+		WHERE completed_at >= $1 AND completed_at < $2
+		GROUP BY 1, 2
+	`,
+}
+
+func (r *MetricRepository) HourlySamples(ctx context.Context, metric monitoring.Metric, from, to time.Time) ([]monitoring.Sample, error) {
+	query, ok := metricQueries[metric]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", metric)
+	}
+	var samples []monitoring.Sample
+	if err := r.db.SelectContext(ctx, &samples, query, from, to); err != nil {
+		return nil, errors.Wrap(err, "failed to load hourly "+string(metric))
+	}
+	return samples, nil
+}
+
+// ListAdminIDs returns the active admins who receive ops alerts.
+func (r *MetricRepository) ListAdminIDs(ctx context.Context) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	query := `
+		SELECT id FROM customer_schema.users
+		WHERE user_type = 'admin' AND is_active = TRUE AND user_status = 'active'
+	`
+	if err := r.db.SelectContext(ctx, &ids, query); err != nil {
+		return nil, errors.Wrap(err, "failed to list admins")
+	}
+	return ids, nil
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// MFARepository keeps login codes, backup codes and the MFA policy.
+type MFARepository struct {
+	db *sqlx.DB
+}
+
+func NewMFARepository(db *sqlx.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+func (r *MFARepository) CreateMFACode(ctx context.Context, c *domain.MFACode) error {
+	query := `
+		INSERT INTO customer_schema.mfa_codes (id, user_id, method, code_hash, attempts, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.ExecContext(ctx, query, c.ID, c.UserID, c.Method, c.CodeHash, c.Attempts, c.ExpiresAt, c.CreatedAt)
+	return errors.Wrap(err, "failed to create mfa code")
+}
+
+func (r *MFARepository) LatestMFACode(ctx context.Context, userID uuid.UUID) (*domain.MFACode, error) {
+	var c domain.MFACode
+	query := `
+		SELECT id, user_id, method, code_hash, attempts, expires_at, consumed_at, created_at
+		FROM customer_schema.mfa_codes
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	err := r.db.GetContext(ctx, &c, query, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get mfa code")
+	}
+	return &c, nil
+}
+
+func (r *MFARepository) CountMFACodesSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM customer_schema.mfa_codes WHERE user_id = $1 AND created_at >= $2`
+	if err := r.db.GetContext(ctx, &count, query, userID, since); err != nil {
+		return 0, errors.Wrap(err, "failed to count mfa codes")
+	}
+	return count, nil
+}
+
+func (r *MFARepository) IncrementMFACodeAttempts(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE customer_schema.mfa_codes SET attempts = attempts + 1 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return errors.Wrap(err, "failed to record mfa code attempt")
+}
+
+func (r *MFARepository) ConsumeMFACode(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE customer_schema.mfa_codes SET consumed_at = NOW() WHERE id = $1 AND consumed_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return errors.Wrap(err, "failed to consume mfa code")
+}
+
+// ReplaceBackupCodes deletes the user's backup codes, used or not, and
+// stores the new hashes in one transaction.
+func (r *MFARepository) ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM customer_schema.mfa_backup_codes WHERE user_id = $1`, userID); err != nil {
+		return errors.Wrap(err, "failed to delete backup codes")
+	}
+	for _, h := range hashes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO customer_schema.mfa_backup_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, NOW())
+		`, uuid.New(), userID, h)
+		if err != nil {
+			return errors.Wrap(err, "failed to store backup code")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit backup codes")
+	}
+	return nil
+}
+
+// UseBackupCode marks the code used in the same statement that finds it, so
+// a code cannot be spent twice by concurrent logins.
+func (r *MFARepository) UseBackupCode(ctx context.Context, userID uuid.UUID, hash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.mfa_backup_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hash)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to use backup code")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *MFARepository) CountBackupCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM customer_schema.mfa_backup_codes WHERE user_id = $1 AND used_at IS NULL`
+	if err := r.db.GetContext(ctx, &count, query, userID); err != nil {
+		return 0, errors.Wrap(err, "failed to count backup codes")
+	}
+	return count, nil
+}
+
+// GetMFAPolicy returns nil until an admin has set a policy.
+func (r *MFARepository) GetMFAPolicy(ctx context.Context) (*domain.MFAPolicy, error) {
+	var p domain.MFAPolicy
+	err := r.db.GetContext(ctx, &p, `
+		SELECT required_for_admins, required_kyc_level, updated_by, updated_at
+		FROM admin_schema.mfa_policy
+	`)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get mfa policy")
+	}
+	return &p, nil
+}
+
+func (r *MFARepository) SetMFAPolicy(ctx context.Context, p *domain.MFAPolicy) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.mfa_policy (id, required_for_admins, required_kyc_level, updated_by, updated_at)
+		VALUES (TRUE, $1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE
+		SET required_for_admins = EXCLUDED.required_for_admins,
+			required_kyc_level = EXCLUDED.required_kyc_level,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+	`, p.RequiredForAdmins, p.RequiredKYCLevel, p.UpdatedBy, p.UpdatedAt)
+	return errors.Wrap(err, "failed to set mfa policy")
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+const notificationColumns = `id, user_id, type, title, message, data, is_read, read_at, is_archived, created_at`
+
 // NotificationRepository provides access to customer_schema.notifications.
 type NotificationRepository struct {
 	db *sqlx.DB
@@ -52,16 +54,22 @@ func (r *NotificationRepository) Create(ctx context.Context, n *domain.Notificat
 	return nil
 }
 
-// ListByUser returns notifications for a specific user with pagination.
-func (r *NotificationRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Notification, int, error) {
+// ListByUser returns notifications for a specific user with pagination,
+// only the unread ones when unreadOnly is set. The total counts the same
+// notifications the page is taken from.
+func (r *NotificationRepository) ListByUser(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]*domain.Notification, int, error) {
 	var rows []*domain.Notification
 
+	where := "WHERE user_id = $1 AND is_archived = FALSE"
+	if unreadOnly {
+		where += " AND is_read = FALSE"
+	}
+
 	query := `
 		SELECT
-			id, user_id, type, title, message, data,
-			is_read, is_archived, created_at
+			` + notificationColumns + `
 		FROM customer_schema.notifications
-		WHERE user_id = $1 AND is_archived = FALSE
+		` + where + `
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -71,11 +79,7 @@ func (r *NotificationRepository) ListByUser(ctx context.Context, userID uuid.UUI
 	}
 
 	var total int
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM customer_schema.notifications
-		WHERE user_id = $1 AND is_archived = FALSE
-	`
+	countQuery := `SELECT COUNT(*) FROM customer_schema.notifications ` + where
 	if err := r.db.GetContext(ctx, &total, countQuery, userID); err != nil {
 		return nil, 0, errors.Wrap(err, "failed to count notifications")
 	}
@@ -83,14 +87,84 @@ func (r *NotificationRepository) ListByUser(ctx context.Context, userID uuid.UUI
 	return rows, total, nil
 }
 
+// CountUnread returns how many of the user's notifications are unread.
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	query := `
+		SELECT COUNT(*)
+		FROM customer_schema.notifications
+		WHERE user_id = $1 AND is_read = FALSE AND is_archived = FALSE
+	`
+	if err := r.db.GetContext(ctx, &n, query, userID); err != nil {
+		return 0, errors.Wrap(err, "failed to count unread notifications")
+	}
+	return n, nil
+}
+
+// MarkReadForUser marks one of the user's notifications read, reporting
+// false when the user has no such notification.
+func (r *NotificationRepository) MarkReadForUser(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE customer_schema.notifications
+		SET is_read = TRUE, read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2 AND is_archived = FALSE
+	`
+	res, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to mark notification read")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to mark notification read")
+	}
+	return n > 0, nil
+}
+
+// MarkAllReadForUser marks every unread notification of the user read and
+// returns how many there were.
+func (r *NotificationRepository) MarkAllReadForUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		UPDATE customer_schema.notifications
+		SET is_read = TRUE, read_at = NOW()
+		WHERE user_id = $1 AND is_read = FALSE AND is_archived = FALSE
+	`
+	res, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to mark all notifications read")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to mark all notifications read")
+	}
+	return int(n), nil
+}
+
+// ArchiveForUser hides one of the user's notifications, reporting false
+// when the user has no such notification.
+func (r *NotificationRepository) ArchiveForUser(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE customer_schema.notifications
+		SET is_archived = TRUE
+		WHERE id = $1 AND user_id = $2 AND is_archived = FALSE
+	`
+	res, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to archive notification")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to archive notification")
+	}
+	return n > 0, nil
+}
+
 // ListAll returns all notifications for admin views with pagination.
 func (r *NotificationRepository) ListAll(ctx context.Context, limit, offset int) ([]*domain.Notification, int, error) {
 	var rows []*domain.Notification
 
 	query := `
 		SELECT
-			id, user_id, type, title, message, data,
-			is_read, is_archived, created_at
+			` + notificationColumns + `
 		FROM customer_schema.notifications
 		WHERE is_archived = FALSE
 		ORDER BY created_at DESC
@@ -117,7 +191,7 @@ func (r *NotificationRepository) ListAll(ctx context.Context, limit, offset int)
 func (r *NotificationRepository) MarkRead(ctx context.Context, id uuid.UUID) error {
 	query := `
 		UPDATE customer_schema.notifications
-		SET is_read = TRUE
+		SET is_read = TRUE, read_at = COALESCE(read_at, NOW())
 		WHERE id = $1
 	`
 	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
@@ -129,7 +203,7 @@ func (r *NotificationRepository) MarkRead(ctx context.Context, id uuid.UUID) err
 func (r *NotificationRepository) MarkAllRead(ctx context.Context) error {
 	query := `
 		UPDATE customer_schema.notifications
-		SET is_read = TRUE
+		SET is_read = TRUE, read_at = NOW()
 		WHERE is_read = FALSE AND is_archived = FALSE
 	`
 	if _, err := r.db.ExecContext(ctx, query); err != nil {
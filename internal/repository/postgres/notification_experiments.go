@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/notification"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const notificationExperimentColumns = `id, name, event_type, status, variants, created_by, created_at, stopped_at`
+
+func (r *NotificationRepository) CreateExperiment(ctx context.Context, e *domain.NotificationExperiment) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.notification_experiments (`+notificationExperimentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, e.ID, e.Name, e.EventType, e.Status, e.Variants, e.CreatedBy, e.CreatedAt, e.StoppedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "idx_notification_experiments_running" { // unique_violation
+		return notification.ErrExperimentRunning
+	}
+	return errors.Wrap(err, "failed to create notification experiment")
+}
+
+func (r *NotificationRepository) GetExperiment(ctx context.Context, id uuid.UUID) (*domain.NotificationExperiment, error) {
+	var e domain.NotificationExperiment
+	query := `SELECT ` + notificationExperimentColumns + ` FROM customer_schema.notification_experiments WHERE id = $1`
+	err := r.db.GetContext(ctx, &e, query, id)
+	if err == sql.ErrNoRows {
+		return nil, notification.ErrExperimentNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get notification experiment")
+	}
+	return &e, nil
+}
+
+func (r *NotificationRepository) ListExperiments(ctx context.Context, limit, offset int) ([]domain.NotificationExperiment, int, error) {
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.notification_experiments`); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count notification experiments")
+	}
+	items := []domain.NotificationExperiment{}
+	query := `
+		SELECT ` + notificationExperimentColumns + `
+		FROM customer_schema.notification_experiments
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`
+	if err := r.db.SelectContext(ctx, &items, query, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list notification experiments")
+	}
+	return items, total, nil
+}
+
+// RunningExperiment returns the event's running experiment, or nil when it
+// has none.
+func (r *NotificationRepository) RunningExperiment(ctx context.Context, eventType string) (*domain.NotificationExperiment, error) {
+	var e domain.NotificationExperiment
+	query := `
+		SELECT ` + notificationExperimentColumns + `
+		FROM customer_schema.notification_experiments
+		WHERE event_type = $1 AND status = 'running'
+	`
+	err := r.db.GetContext(ctx, &e, query, eventType)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get running notification experiment")
+	}
+	return &e, nil
+}
+
+// StopExperiment stops a running experiment, reporting false when there
+// is no such experiment running.
+func (r *NotificationRepository) StopExperiment(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.notification_experiments
+		SET status = 'stopped', stopped_at = $2
+		WHERE id = $1 AND status = 'running'
+	`, id, at)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to stop notification experiment")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to stop notification experiment")
+	}
+	return n > 0, nil
+}
+
+func (r *NotificationRepository) RecordDelivery(ctx context.Context, d *domain.NotificationDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.notification_deliveries (notification_id, experiment_id, variant, user_id, delivered_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, d.NotificationID, d.ExperimentID, d.Variant, d.UserID, d.DeliveredAt)
+	return errors.Wrap(err, "failed to record notification delivery")
+}
+
+// TrackDelivery marks the user's notification opened, or clicked and
+// opened, keeping the first time of each.
+func (r *NotificationRepository) TrackDelivery(ctx context.Context, userID, notificationID uuid.UUID, event notification.TrackingEvent, at time.Time) (bool, error) {
+	set := `opened_at = COALESCE(opened_at, $3)`
+	if event == notification.TrackClicked {
+		set += `, clicked_at = COALESCE(clicked_at, $3)`
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.notification_deliveries
+		SET `+set+`
+		WHERE notification_id = $1 AND user_id = $2
+	`, notificationID, userID, at)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to track notification delivery")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to track notification delivery")
+	}
+	return n > 0, nil
+}
+
+// ExperimentResults counts the experiment's deliveries, opens and clicks
+// per variant. Variants nothing was sent as are left out.
+func (r *NotificationRepository) ExperimentResults(ctx context.Context, id uuid.UUID) ([]domain.NotificationVariantResult, error) {
+	items := []domain.NotificationVariantResult{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT variant,
+			COUNT(DISTINCT user_id) AS users,
+			COUNT(*) AS delivered,
+			COUNT(opened_at) AS opened,
+			COUNT(clicked_at) AS clicked
+		FROM customer_schema.notification_deliveries
+		WHERE experiment_id = $1
+		GROUP BY variant
+	`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count notification experiment results")
+	}
+	return items, nil
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+)
+
+const pushTokenColumns = `id, user_id, provider, token, device_name, created_at, updated_at`
+
+// SavePushToken registers the token for its user. A token already on
+// record keeps its ID and moves to the user, since it belongs to one app
+// install.
+func (r *NotificationRepository) SavePushToken(ctx context.Context, t *domain.PushToken) error {
+	query := `
+		INSERT INTO customer_schema.push_tokens (` + pushTokenColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (token) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			provider = EXCLUDED.provider,
+			device_name = EXCLUDED.device_name,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowxContext(ctx, query, t.ID, t.UserID, t.Provider, t.Token, t.DeviceName, t.CreatedAt, t.UpdatedAt).
+		Scan(&t.ID, &t.CreatedAt)
+	return errors.Wrap(err, "failed to save push token")
+}
+
+// ListPushTokens returns the user's registered devices, most recently
+// registered first.
+func (r *NotificationRepository) ListPushTokens(ctx context.Context, userID uuid.UUID) ([]domain.PushToken, error) {
+	items := []domain.PushToken{}
+	query := `SELECT ` + pushTokenColumns + ` FROM customer_schema.push_tokens WHERE user_id = $1 ORDER BY updated_at DESC`
+	if err := r.db.SelectContext(ctx, &items, query, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to list push tokens")
+	}
+	return items, nil
+}
+
+func (r *NotificationRepository) DeletePushToken(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM customer_schema.push_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete push token")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete push token")
+	}
+	return n > 0, nil
+}
+
+func (r *NotificationRepository) ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]domain.NotificationPreference, error) {
+	items := []domain.NotificationPreference{}
+	query := `
+		SELECT event_type, channel, enabled
+		FROM customer_schema.notification_preferences
+		WHERE user_id = $1
+	`
+	if err := r.db.SelectContext(ctx, &items, query, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to list notification preferences")
+	}
+	return items, nil
+}
+
+func (r *NotificationRepository) SetNotificationPreferences(ctx context.Context, userID uuid.UUID, prefs []domain.NotificationPreference) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	for _, p := range prefs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO customer_schema.notification_preferences (user_id, event_type, channel, enabled, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (user_id, event_type, channel) DO UPDATE SET
+				enabled = EXCLUDED.enabled,
+				updated_at = EXCLUDED.updated_at
+		`, userID, p.EventType, p.Channel, p.Enabled)
+		if err != nil {
+			return errors.Wrap(err, "failed to set notification preference")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit notification preferences")
+}
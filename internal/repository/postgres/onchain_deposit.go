@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OnchainDepositRepository struct {
+	db *sqlx.DB
+}
+
+func NewOnchainDepositRepository(db *sqlx.DB) *OnchainDepositRepository {
+	return &OnchainDepositRepository{db: db}
+}
+
+func (r *OnchainDepositRepository) Create(ctx context.Context, d *domain.OnchainDeposit) (bool, error) {
+	query := `
+		INSERT INTO customer_schema.onchain_deposits (
+			id, network, tx_hash, receiving_address, sender_address, memo,
+			amount, currency, block_height, block_hash, confirmations, status,
+			wallet_id, credit_reference, failure_reason, confirmed_at, credited_at,
+			created_at, updated_at
+		) VALUES (
+			$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19
+		)
+		ON CONFLICT (network, tx_hash, receiving_address) DO NOTHING
+	`
+	res, err := r.db.ExecContext(ctx, query,
+		d.ID, d.Network, d.TxHash, d.ReceivingAddress, d.SenderAddress, d.Memo,
+		d.Amount, d.Currency, d.BlockHeight, d.BlockHash, d.Confirmations, d.Status,
+		d.WalletID, d.CreditReference, d.FailureReason, d.ConfirmedAt, d.CreditedAt,
+		d.CreatedAt, d.UpdatedAt,
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create onchain deposit")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create onchain deposit")
+	}
+	return n > 0, nil
+}
+
+func (r *OnchainDepositRepository) Update(ctx context.Context, d *domain.OnchainDeposit) error {
+	query := `
+		UPDATE customer_schema.onchain_deposits SET
+			confirmations = $1,
+			status = $2,
+			wallet_id = $3,
+			credit_reference = $4,
+			failure_reason = $5,
+			confirmed_at = $6,
+			credited_at = $7,
+			updated_at = $8
+		WHERE id = $9
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		d.Confirmations, d.Status, d.WalletID, d.CreditReference, d.FailureReason,
+		d.ConfirmedAt, d.CreditedAt, d.UpdatedAt, d.ID,
+	)
+	return errors.Wrap(err, "failed to update onchain deposit")
+}
+
+func (r *OnchainDepositRepository) FindByStatus(ctx context.Context, network domain.BlockchainNetwork, status domain.OnchainDepositStatus, limit int) ([]*domain.OnchainDeposit, error) {
+	var items []*domain.OnchainDeposit
+	query := `
+		SELECT *
+		FROM customer_schema.onchain_deposits
+		WHERE network = $1 AND status = $2
+		ORDER BY block_height ASC, created_at ASC
+		LIMIT $3
+	`
+	if err := r.db.SelectContext(ctx, &items, query, network, status, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to find onchain deposits")
+	}
+	return items, nil
+}
+
+// GetCursor returns the last scanned block height for a network, or 0 if the
+// network has never been scanned.
+func (r *OnchainDepositRepository) GetCursor(ctx context.Context, network domain.BlockchainNetwork) (int64, error) {
+	var height int64
+	err := r.db.GetContext(ctx, &height,
+		`SELECT last_height FROM customer_schema.chain_scan_cursors WHERE network = $1`, network)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get scan cursor")
+	}
+	return height, nil
+}
+
+func (r *OnchainDepositRepository) SetCursor(ctx context.Context, network domain.BlockchainNetwork, height int64) error {
+	query := `
+		INSERT INTO customer_schema.chain_scan_cursors (network, last_height, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (network) DO UPDATE SET last_height = EXCLUDED.last_height, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, network, height, time.Now())
+	return errors.Wrap(err, "failed to set scan cursor")
+}
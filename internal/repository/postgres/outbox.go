@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const outboxColumns = `id, event_type, aggregate_type, aggregate_id, payload, attempts, last_error,
+	next_attempt_at, published_at, created_at`
+
+// OutboxRepository keeps domain events until the dispatcher has published
+// them. Events are written by the repositories whose changes they describe,
+// inside the same transaction; see writeOutbox.
+type OutboxRepository struct {
+	db *sqlx.DB
+}
+
+func NewOutboxRepository(db *sqlx.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// writeOutbox stores e in tx, so that it is published only if tx commits.
+func writeOutbox(ctx context.Context, tx *sqlx.Tx, e *domain.OutboxEvent) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO admin_schema.outbox_events (
+			id, event_type, aggregate_type, aggregate_id, payload, next_attempt_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, e.ID, e.Type, e.AggregateType, e.AggregateID, e.Payload, e.NextAttemptAt, e.CreatedAt)
+	return errors.Wrap(err, "failed to write outbox event")
+}
+
+// ClaimDue returns up to limit unpublished events due at now, oldest first,
+// and pushes their next attempt back by lease so that other dispatchers
+// skip them meanwhile.
+func (r *OutboxRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.OutboxEvent, error) {
+	events := []domain.OutboxEvent{}
+	err := r.db.SelectContext(ctx, &events, `
+		UPDATE admin_schema.outbox_events
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM admin_schema.outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= $1
+			ORDER BY created_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+outboxColumns,
+		now, now.Add(lease), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim outbox events")
+	}
+	return events, nil
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.outbox_events
+		SET published_at = $2, attempts = attempts + 1, last_error = ''
+		WHERE id = $1
+	`, id, at)
+	return errors.Wrap(err, "failed to mark outbox event published")
+}
+
+// MarkFailed records a failed attempt and when to try again.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, next time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.outbox_events
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1
+	`, id, lastError, next)
+	return errors.Wrap(err, "failed to record outbox attempt")
+}
+
+// ListOutbox returns events newest first: the pending ones when pending is
+// true, the published ones otherwise.
+func (r *OutboxRepository) ListOutbox(ctx context.Context, pending bool, eventType domain.OutboxEventType, limit, offset int) ([]domain.OutboxEvent, int, error) {
+	where := `WHERE (published_at IS NULL) = $1 AND ($2 = '' OR event_type = $2)`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.outbox_events `+where, pending, eventType); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count outbox events")
+	}
+	events := []domain.OutboxEvent{}
+	err := r.db.SelectContext(ctx, &events, `
+		SELECT `+outboxColumns+` FROM admin_schema.outbox_events `+where+`
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, pending, eventType, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list outbox events")
+	}
+	return events, total, nil
+}
+
+// PruneOutbox deletes events published before before.
+func (r *OutboxRepository) PruneOutbox(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM admin_schema.outbox_events
+		WHERE published_at IS NOT NULL AND published_at < $1
+	`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to prune outbox events")
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
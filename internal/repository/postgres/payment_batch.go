@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/paymentbatch"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// paymentBatchLease is how long a batch may go without progress in
+// processing before another worker assumes the previous one died and
+// resumes it.
+const paymentBatchLease = 5 * time.Minute
+
+const paymentBatchColumns = `
+	id, user_id, file_name, format, reference, status, total_items, invalid_items, processed_items,
+	succeeded_items, failed_items, created_at, started_at, completed_at, updated_at
+`
+
+const paymentBatchItemColumns = `batch_id, line, record, status, errors, transaction_id, processed_at`
+
+// PaymentBatchRepository stores customers' payment batches and the outcome
+// of each of their items.
+type PaymentBatchRepository struct {
+	db *sqlx.DB
+}
+
+func NewPaymentBatchRepository(db *sqlx.DB) *PaymentBatchRepository {
+	return &PaymentBatchRepository{db: db}
+}
+
+func (r *PaymentBatchRepository) CreateBatch(ctx context.Context, b *domain.PaymentBatch, items []domain.PaymentBatchItem) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO customer_schema.payment_batches (`+paymentBatchColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15)
+	`, b.ID, b.UserID, b.FileName, b.Format, b.Reference, b.Status, b.TotalItems, b.InvalidItems, b.ProcessedItems,
+		b.SucceededItems, b.FailedItems, b.CreatedAt, b.StartedAt, b.CompletedAt, b.UpdatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return paymentbatch.ErrReferenceExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to create payment batch")
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO customer_schema.payment_batch_items (`+paymentBatchItemColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+	`)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare payment batch items")
+	}
+	defer stmt.Close()
+	for i := range items {
+		item := &items[i]
+		if _, err := stmt.ExecContext(ctx, item.BatchID, item.Line, item.Record, item.Status, item.Errors, item.TransactionID, item.ProcessedAt); err != nil {
+			return errors.Wrap(err, "failed to create payment batch item")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit payment batch")
+}
+
+func (r *PaymentBatchRepository) GetBatch(ctx context.Context, id uuid.UUID) (*domain.PaymentBatch, error) {
+	var b domain.PaymentBatch
+	err := r.db.GetContext(ctx, &b, `SELECT `+paymentBatchColumns+` FROM customer_schema.payment_batches WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, paymentbatch.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get payment batch")
+	}
+	return &b, nil
+}
+
+func (r *PaymentBatchRepository) ListBatches(ctx context.Context, userID uuid.UUID, limit, offset int) ([]domain.PaymentBatch, int, error) {
+	items := []domain.PaymentBatch{}
+	query := `
+		SELECT ` + paymentBatchColumns + `
+		FROM customer_schema.payment_batches
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	if err := r.db.SelectContext(ctx, &items, query, userID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list payment batches")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.payment_batches WHERE user_id = $1`, userID); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count payment batches")
+	}
+	return items, total, nil
+}
+
+func (r *PaymentBatchRepository) ListItems(ctx context.Context, batchID uuid.UUID, status domain.PaymentBatchItemStatus, limit, offset int) ([]domain.PaymentBatchItem, int, error) {
+	where := "WHERE batch_id = $1"
+	args := []interface{}{batchID}
+	if status != "" {
+		where += " AND status = $2"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.payment_batch_items `+where, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count payment batch items")
+	}
+
+	items := []domain.PaymentBatchItem{}
+	query := fmt.Sprintf(`SELECT %s FROM customer_schema.payment_batch_items %s ORDER BY line LIMIT $%d OFFSET $%d`,
+		paymentBatchItemColumns, where, len(args)+1, len(args)+2)
+	if err := r.db.SelectContext(ctx, &items, query, append(args, limit, offset)...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list payment batch items")
+	}
+	return items, total, nil
+}
+
+func (r *PaymentBatchRepository) ClaimBatch(ctx context.Context, now time.Time) (*domain.PaymentBatch, error) {
+	var b domain.PaymentBatch
+	query := `
+		UPDATE customer_schema.payment_batches
+		SET status = $1, started_at = COALESCE(started_at, $3), updated_at = $3
+		WHERE id = (
+			SELECT id FROM customer_schema.payment_batches
+			WHERE status = $2
+			   OR (status = $1 AND updated_at < $4)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + paymentBatchColumns
+	err := r.db.GetContext(ctx, &b, query,
+		domain.PaymentBatchProcessing, domain.PaymentBatchQueued, now, now.Add(-paymentBatchLease))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim payment batch")
+	}
+	return &b, nil
+}
+
+func (r *PaymentBatchRepository) PendingItems(ctx context.Context, batchID uuid.UUID, limit int) ([]domain.PaymentBatchItem, error) {
+	var items []domain.PaymentBatchItem
+	query := `
+		SELECT ` + paymentBatchItemColumns + `
+		FROM customer_schema.payment_batch_items
+		WHERE batch_id = $1 AND status = $2
+		ORDER BY line
+		LIMIT $3
+	`
+	if err := r.db.SelectContext(ctx, &items, query, batchID, domain.PaymentBatchItemPending, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to list pending payment batch items")
+	}
+	return items, nil
+}
+
+func (r *PaymentBatchRepository) FinishItem(ctx context.Context, item *domain.PaymentBatchItem) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.payment_batch_items
+		SET status = $3, errors = $4, transaction_id = $5, processed_at = $6
+		WHERE batch_id = $1 AND line = $2 AND status = $7
+	`, item.BatchID, item.Line, item.Status, item.Errors, item.TransactionID, item.ProcessedAt, domain.PaymentBatchItemPending)
+	if err != nil {
+		return errors.Wrap(err, "failed to finish payment batch item")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// Already finished by another worker; counting it again would skew
+		// the batch's progress.
+		return errors.Wrap(err, "failed to finish payment batch item")
+	}
+
+	succeeded, failed := 0, 0
+	if item.Status == domain.PaymentBatchItemSucceeded {
+		succeeded = 1
+	} else {
+		failed = 1
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE customer_schema.payment_batches
+		SET processed_items = processed_items + 1,
+		    succeeded_items = succeeded_items + $2,
+		    failed_items = failed_items + $3,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, item.BatchID, succeeded, failed)
+	if err != nil {
+		return errors.Wrap(err, "failed to count payment batch item")
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit payment batch item")
+}
+
+func (r *PaymentBatchRepository) CompleteBatch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE customer_schema.payment_batches
+		SET status = $2, completed_at = $3, updated_at = $3
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, domain.PaymentBatchCompleted, at)
+	return errors.Wrap(err, "failed to complete payment batch")
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/paymentbatch"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentBatchRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewPaymentBatchRepository(db)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+	userID := testUser(t, db, now)
+
+	t.Run("references are unique per user", func(t *testing.T) {
+		testPaymentBatch(t, db, repo, userID, "MAY", now, 1)
+		dup := newTestPaymentBatch(userID, "MAY", now)
+		assert.ErrorIs(t, repo.CreateBatch(ctx, dup, nil), paymentbatch.ErrReferenceExists)
+
+		// Batches without a reference do not clash
+		testPaymentBatch(t, db, repo, userID, "", now, 1)
+		testPaymentBatch(t, db, repo, userID, "", now, 1)
+		otherID := testUser(t, db, now)
+		testPaymentBatch(t, db, repo, otherID, "MAY", now, 1)
+
+		_, err := repo.GetBatch(ctx, uuid.New())
+		assert.ErrorIs(t, err, paymentbatch.ErrNotFound)
+	})
+
+	t.Run("claims the oldest queued batch once", func(t *testing.T) {
+		// Older than anything else queued, so these are what is claimed
+		b := testPaymentBatch(t, db, repo, userID, "", now.Add(-time.Hour), 1)
+		next := testPaymentBatch(t, db, repo, userID, "", now.Add(-time.Minute), 1)
+		claimed, err := repo.ClaimBatch(ctx, now)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, b.ID, claimed.ID)
+		assert.Equal(t, domain.PaymentBatchProcessing, claimed.Status)
+		assert.True(t, claimed.StartedAt.Equal(now))
+
+		again, err := repo.ClaimBatch(ctx, now.Add(time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, again)
+		assert.Equal(t, next.ID, again.ID, "the first is claimed already")
+		require.NoError(t, repo.CompleteBatch(ctx, next.ID, now))
+
+		// Nothing was finished within the lease; the worker died
+		later := now.Add(paymentBatchLease + time.Second)
+		reclaimed, err := repo.ClaimBatch(ctx, later)
+		require.NoError(t, err)
+		require.NotNil(t, reclaimed)
+		assert.Equal(t, b.ID, reclaimed.ID)
+		assert.True(t, reclaimed.StartedAt.Equal(now), "started when first claimed")
+		require.NoError(t, repo.CompleteBatch(ctx, b.ID, later))
+	})
+
+	t.Run("items are counted once", func(t *testing.T) {
+		b := testPaymentBatch(t, db, repo, userID, "", now, 3)
+		pending, err := repo.PendingItems(ctx, b.ID, 2)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		assert.Equal(t, []int{1, 2}, []int{pending[0].Line, pending[1].Line})
+
+		item := pending[0]
+		item.Status, item.ProcessedAt = domain.PaymentBatchItemFailed, &now
+		item.Errors = domain.PaymentBatchErrors{{Message: "insufficient balance"}}
+		require.NoError(t, repo.FinishItem(ctx, &item))
+		require.NoError(t, repo.FinishItem(ctx, &item), "finished by another worker")
+
+		got, err := repo.GetBatch(ctx, b.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.ProcessedItems)
+		assert.Equal(t, 1, got.FailedItems)
+
+		failed, total, err := repo.ListItems(ctx, b.ID, domain.PaymentBatchItemFailed, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, "insufficient balance", failed[0].Errors[0].Message)
+		rest, total, err := repo.ListItems(ctx, b.ID, "", 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, 2, rest[0].Line)
+	})
+}
+
+func newTestPaymentBatch(userID uuid.UUID, reference string, at time.Time) *domain.PaymentBatch {
+	return &domain.PaymentBatch{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Format:    paymentbatch.FormatCSV,
+		Reference: reference,
+		Status:    domain.PaymentBatchQueued,
+		CreatedAt: at,
+		UpdatedAt: at,
+	}
+}
+
+// testPaymentBatch queues a batch of n pending items created at at, and
+// removes it after the test.
+func testPaymentBatch(t *testing.T, db *sqlx.DB, repo *PaymentBatchRepository, userID uuid.UUID, reference string, at time.Time, n int) *domain.PaymentBatch {
+	t.Helper()
+	b := newTestPaymentBatch(userID, reference, at)
+	b.TotalItems = n
+	items := make([]domain.PaymentBatchItem, n)
+	for i := range items {
+		items[i] = domain.PaymentBatchItem{
+			BatchID: b.ID,
+			Line:    i + 1,
+			Record:  domain.PaymentBatchRecord{ReceiverID: uuid.NewString(), Amount: "10", Currency: "MWK"},
+			Status:  domain.PaymentBatchItemPending,
+		}
+	}
+	require.NoError(t, repo.CreateBatch(context.Background(), b, items))
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.payment_batches WHERE id = $1`, b.ID) })
+	return b
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PaymentDisclosureRepository keeps pre-payment disclosures as issued.
+type PaymentDisclosureRepository struct {
+	db *sqlx.DB
+}
+
+func NewPaymentDisclosureRepository(db *sqlx.DB) *PaymentDisclosureRepository {
+	return &PaymentDisclosureRepository{db: db}
+}
+
+const paymentDisclosureColumns = `
+	id, user_id, sender_wallet_id, receiver_wallet_id, recipient_name, transaction_id,
+	transfer_amount, transfer_currency, transfer_fees, transfer_taxes, total_cost,
+	exchange_rate, mid_market_rate, fx_margin_percent, fx_margin_amount,
+	receive_amount, receive_currency, other_fees, total_to_recipient,
+	available_by, subject_to_review, rights_notice, issued_at, expires_at
+`
+
+func (r *PaymentDisclosureRepository) Create(ctx context.Context, d *domain.PaymentDisclosure) error {
+	query := `
+		INSERT INTO customer_schema.payment_disclosures (` + paymentDisclosureColumns + `)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		d.ID, d.UserID, d.SenderWalletID, d.ReceiverWalletID, d.RecipientName, d.TransactionID,
+		d.TransferAmount, d.TransferCurrency, d.TransferFees, d.TransferTaxes, d.TotalCost,
+		d.ExchangeRate, d.MidMarketRate, d.FXMarginPercent, d.FXMarginAmount,
+		d.ReceiveAmount, d.ReceiveCurrency, d.OtherFees, d.TotalToRecipient,
+		d.AvailableBy, d.SubjectToReview, d.RightsNotice, d.IssuedAt, d.ExpiresAt)
+	return errors.Wrap(err, "failed to create payment disclosure")
+}
+
+func (r *PaymentDisclosureRepository) Find(ctx context.Context, id uuid.UUID) (*domain.PaymentDisclosure, error) {
+	return r.findBy(ctx, "id", id)
+}
+
+func (r *PaymentDisclosureRepository) FindByTransaction(ctx context.Context, txID uuid.UUID) (*domain.PaymentDisclosure, error) {
+	return r.findBy(ctx, "transaction_id", txID)
+}
+
+func (r *PaymentDisclosureRepository) findBy(ctx context.Context, column string, id uuid.UUID) (*domain.PaymentDisclosure, error) {
+	var d domain.PaymentDisclosure
+	query := `SELECT ` + paymentDisclosureColumns + ` FROM customer_schema.payment_disclosures WHERE ` + column + ` = $1`
+	err := r.db.GetContext(ctx, &d, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find payment disclosure")
+	}
+	return &d, nil
+}
+
+func (r *PaymentDisclosureRepository) Attach(ctx context.Context, id, txID uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.payment_disclosures
+		SET transaction_id = $2
+		WHERE id = $1 AND transaction_id IS NULL
+	`, id, txID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to attach payment disclosure")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to attach payment disclosure")
+	}
+	return n > 0, nil
+}
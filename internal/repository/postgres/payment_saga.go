@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// PaymentSagaRepository keeps the progress of payment initiations.
+type PaymentSagaRepository struct {
+	db *sqlx.DB
+}
+
+func NewPaymentSagaRepository(db *sqlx.DB) *PaymentSagaRepository {
+	return &PaymentSagaRepository{db: db}
+}
+
+func (r *PaymentSagaRepository) Begin(ctx context.Context, saga *domain.PaymentSaga) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.payment_sagas (
+			transaction_id, reference, sender_wallet_id, total_debit, currency, step, state, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, saga.TransactionID, saga.Reference, saga.SenderWalletID, saga.TotalDebit, saga.Currency,
+		saga.Step, saga.State, saga.CreatedAt, saga.UpdatedAt)
+	return errors.Wrap(err, "failed to start payment saga")
+}
+
+// Advance records step on a running saga.
+func (r *PaymentSagaRepository) Advance(ctx context.Context, txID uuid.UUID, step domain.PaymentSagaStep) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.payment_sagas
+		SET step = $2, updated_at = NOW()
+		WHERE transaction_id = $1 AND state = 'running'
+	`, txID, step)
+	return errors.Wrap(err, "failed to advance payment saga")
+}
+
+// ReserveFunds moves amount from available to reserved in the wallet and
+// advances the saga to reserved, in one transaction.
+func (r *PaymentSagaRepository) ReserveFunds(ctx context.Context, txID, walletID uuid.UUID, amount decimal.Decimal) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.wallets SET
+			available_balance = available_balance - $1,
+			reserved_balance = reserved_balance + $1,
+			updated_at = NOW()
+		WHERE id = $2 AND available_balance >= $1
+	`, amount, walletID)
+	if err != nil {
+		return errors.Wrap(err, "failed to reserve funds")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrInsufficientBalance
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.payment_sagas
+		SET step = $2, updated_at = NOW()
+		WHERE transaction_id = $1
+	`, txID, domain.PaymentSagaReserved); err != nil {
+		return errors.Wrap(err, "failed to advance payment saga")
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit reservation")
+}
+
+// Finish closes a running saga; a saga already finished is left as it is.
+func (r *PaymentSagaRepository) Finish(ctx context.Context, txID uuid.UUID, state domain.PaymentSagaState, outcome string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.payment_sagas
+		SET state = $2, outcome = $3, updated_at = NOW(), finished_at = NOW()
+		WHERE transaction_id = $1 AND state = 'running'
+	`, txID, state, outcome)
+	return errors.Wrap(err, "failed to finish payment saga")
+}
+
+func (r *PaymentSagaRepository) FindRunning(ctx context.Context, before time.Time, limit int) ([]*domain.PaymentSaga, error) {
+	var sagas []*domain.PaymentSaga
+	err := r.db.SelectContext(ctx, &sagas, `
+		SELECT transaction_id, reference, sender_wallet_id, total_debit, currency, step, state, outcome,
+			created_at, updated_at, finished_at
+		FROM customer_schema.payment_sagas
+		WHERE state = 'running' AND updated_at < $1
+		ORDER BY updated_at
+		LIMIT $2
+	`, before, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find running payment sagas")
+	}
+	return sagas, nil
+}
+
+// Posted reports whether a ledger posting was committed under key.
+func (r *PaymentSagaRepository) Posted(ctx context.Context, key string) (bool, error) {
+	var posted bool
+	err := r.db.GetContext(ctx, &posted, `
+		SELECT EXISTS (SELECT 1 FROM customer_schema.ledger_postings WHERE idempotency_key = $1)
+	`, key)
+	return posted, errors.Wrap(err, "failed to look up ledger posting")
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PhoneVerificationRepository struct {
+	db *sqlx.DB
+}
+
+func NewPhoneVerificationRepository(db *sqlx.DB) *PhoneVerificationRepository {
+	return &PhoneVerificationRepository{db: db}
+}
+
+func (r *PhoneVerificationRepository) CreatePhoneCode(ctx context.Context, c *domain.PhoneVerificationCode) error {
+	query := `
+		INSERT INTO customer_schema.phone_verification_codes (id, user_id, code_hash, attempts, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.ExecContext(ctx, query, c.ID, c.UserID, c.CodeHash, c.Attempts, c.ExpiresAt, c.CreatedAt)
+	return errors.Wrap(err, "failed to create phone verification code")
+}
+
+func (r *PhoneVerificationRepository) LatestPhoneCode(ctx context.Context, userID uuid.UUID) (*domain.PhoneVerificationCode, error) {
+	var c domain.PhoneVerificationCode
+	query := `
+		SELECT id, user_id, code_hash, attempts, expires_at, consumed_at, created_at
+		FROM customer_schema.phone_verification_codes
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	err := r.db.GetContext(ctx, &c, query, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get phone verification code")
+	}
+	return &c, nil
+}
+
+func (r *PhoneVerificationRepository) CountPhoneCodesSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM customer_schema.phone_verification_codes WHERE user_id = $1 AND created_at >= $2`
+	if err := r.db.GetContext(ctx, &count, query, userID, since); err != nil {
+		return 0, errors.Wrap(err, "failed to count phone verification codes")
+	}
+	return count, nil
+}
+
+func (r *PhoneVerificationRepository) IncrementPhoneCodeAttempts(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE customer_schema.phone_verification_codes SET attempts = attempts + 1 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return errors.Wrap(err, "failed to record phone verification attempt")
+}
+
+func (r *PhoneVerificationRepository) ConsumePhoneCode(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE customer_schema.phone_verification_codes SET consumed_at = NOW() WHERE id = $1 AND consumed_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return errors.Wrap(err, "failed to consume phone verification code")
+}
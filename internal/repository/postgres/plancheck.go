@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// criticalQuery is a hot-path query whose plan is verified at startup.
+type criticalQuery struct {
+	name  string
+	query string
+	args  []interface{}
+}
+
+// criticalQueries are the transaction queries that must stay index-backed as
+// the table grows. The arguments only need the right types; the plans are
+// generic.
+func criticalQueries() []criticalQuery {
+	now := time.Now().UTC()
+	return []criticalQuery{
+		{"TransactionRepository.FindByUserID", userTransactionsQuery(false), []interface{}{uuid.Nil, 50, 0}},
+		{"TransactionRepository.FindByUserIDAfter", userTransactionsQuery(true), []interface{}{uuid.Nil, now, uuid.Nil, 50}},
+		{"TransactionRepository.FindByStatus", transactionsByStatusQuery, []interface{}{domain.TransactionStatusPendingApproval, 50, 0}},
+		{"TransactionRepository.FindByStatusAfter", transactionsByStatusAfterQuery, []interface{}{domain.TransactionStatusPendingApproval, now, uuid.Nil, 50}},
+		{"TransactionRepository.FindPendingSettlement", pendingSettlementQuery, []interface{}{100}},
+		{"TransactionRepository.FindPendingBySender", pendingBySenderQuery, []interface{}{uuid.Nil, pq.Array([]string{string(domain.TransactionStatusPendingApproval)}), 5}},
+		{"TransactionRepository.FindByReferenceNumber", transactionByReferenceNumberQuery, []interface{}{"KYD-00000-00000"}},
+	}
+}
+
+// PlanViolation reports a critical query the planner can only answer by
+// scanning a whole table.
+type PlanViolation struct {
+	Query string
+	Table string
+}
+
+// CheckQueryPlans explains each critical query with sequential scans
+// disabled and logs a warning for every one whose plan still scans a table,
+// which means no index can serve it (typically a dropped or never-applied
+// migration). Plans are taken this way because on a small table the planner
+// rightly prefers a scan even when the index exists. Nothing is executed.
+func CheckQueryPlans(ctx context.Context, db *sqlx.DB, log logger.Logger) ([]PlanViolation, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to begin plan check")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL enable_seqscan = off"); err != nil {
+		return nil, errors.Wrap(err, "failed to disable sequential scans")
+	}
+
+	var violations []PlanViolation
+	for _, q := range criticalQueries() {
+		var raw []byte
+		if err := tx.GetContext(ctx, &raw, "EXPLAIN (FORMAT JSON) "+q.query, q.args...); err != nil {
+			return violations, errors.Wrap(err, "failed to explain "+q.name)
+		}
+		tables, err := seqScannedTables(raw)
+		if err != nil {
+			return violations, errors.Wrap(err, "failed to parse plan of "+q.name)
+		}
+		for _, table := range tables {
+			violations = append(violations, PlanViolation{Query: q.name, Table: table})
+			log.Warn("Critical query is not index-backed", map[string]interface{}{
+				"query": q.name,
+				"table": table,
+				"sql":   compactSQL(q.query),
+			})
+		}
+	}
+	if len(violations) == 0 {
+		log.Info("Critical query plans verified", map[string]interface{}{"queries": len(criticalQueries())})
+	}
+	return violations, nil
+}
+
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// seqScannedTables returns the tables read by a sequential scan anywhere in
+// an EXPLAIN (FORMAT JSON) document.
+func seqScannedTables(raw []byte) ([]string, error) {
+	var doc []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	var tables []string
+	seen := make(map[string]bool)
+	var walk func(n planNode)
+	walk = func(n planNode) {
+		if n.NodeType == "Seq Scan" && !seen[n.RelationName] {
+			seen[n.RelationName] = true
+			tables = append(tables, n.RelationName)
+		}
+		for _, c := range n.Plans {
+			walk(c)
+		}
+	}
+	for _, d := range doc {
+		walk(d.Plan)
+	}
+	return tables, nil
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeqScannedTables(t *testing.T) {
+	indexed := []byte(`[{"Plan": {"Node Type": "Limit", "Plans": [
+		{"Node Type": "Merge Append", "Plans": [
+			{"Node Type": "Index Scan", "Relation Name": "transactions", "Index Name": "idx_tx_sender_created_id"},
+			{"Node Type": "Index Scan", "Relation Name": "transactions", "Index Name": "idx_tx_receiver_created_id"}
+		]}
+	]}}]`)
+	tables, err := seqScannedTables(indexed)
+	require.NoError(t, err)
+	assert.Empty(t, tables)
+
+	scanned := []byte(`[{"Plan": {"Node Type": "Limit", "Plans": [
+		{"Node Type": "Sort", "Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "transactions"},
+			{"Node Type": "Seq Scan", "Relation Name": "transactions"}
+		]}
+	]}}]`)
+	tables, err = seqScannedTables(scanned)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"transactions"}, tables)
+
+	_, err = seqScannedTables([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestUserTransactionsQuery(t *testing.T) {
+	offset := userTransactionsQuery(false)
+	assert.Equal(t, 2, strings.Count(offset, "UNION ALL")+1)
+	assert.Contains(t, offset, "LIMIT $2 OFFSET $3")
+	assert.NotContains(t, offset, " OR ")
+
+	keyset := userTransactionsQuery(true)
+	assert.Equal(t, 2, strings.Count(keyset, "(created_at, id) < ($2, $3)"))
+	assert.NotContains(t, keyset, "OFFSET")
+	assert.Contains(t, keyset, "sender_id <> $1")
+}
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	c := domain.TransactionCursor{
+		CreatedAt: time.Date(2024, 5, 10, 14, 20, 1, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+	parsed, err := domain.ParseTransactionCursor(c.Encode())
+	require.NoError(t, err)
+	assert.True(t, c.CreatedAt.Equal(parsed.CreatedAt))
+	assert.Equal(t, c.ID, parsed.ID)
+
+	_, err = domain.ParseTransactionCursor("bm90LWEtY3Vyc29y")
+	assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+}
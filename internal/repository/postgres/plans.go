@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PlanRepository struct {
+	db *sqlx.DB
+}
+
+func NewPlanRepository(db *sqlx.DB) *PlanRepository {
+	return &PlanRepository{db: db}
+}
+
+func (r *PlanRepository) GetSubscription(ctx context.Context, userID uuid.UUID) (*domain.PlanSubscription, error) {
+	var sub domain.PlanSubscription
+	query := `
+		SELECT user_id, plan, pending_plan, period_start, period_end, updated_at
+		FROM customer_schema.plan_subscriptions
+		WHERE user_id = $1
+	`
+	err := r.db.GetContext(ctx, &sub, query, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get plan subscription")
+	}
+	return &sub, nil
+}
+
+func (r *PlanRepository) SaveSubscription(ctx context.Context, sub *domain.PlanSubscription) error {
+	query := `
+		INSERT INTO customer_schema.plan_subscriptions (user_id, plan, pending_plan, period_start, period_end, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			plan = EXCLUDED.plan,
+			pending_plan = EXCLUDED.pending_plan,
+			period_start = EXCLUDED.period_start,
+			period_end = EXCLUDED.period_end,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, sub.UserID, sub.Plan, sub.PendingPlan, sub.PeriodStart, sub.PeriodEnd, sub.UpdatedAt)
+	return errors.Wrap(err, "failed to save plan subscription")
+}
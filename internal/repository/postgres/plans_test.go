@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewPlanRepository(db)
+	march := time.Date(1990, 3, 1, 0, 0, 0, 0, time.UTC)
+	user := testUser(t, db, march)
+
+	sub, err := repo.GetSubscription(ctx, user)
+	require.NoError(t, err)
+	assert.Nil(t, sub, "never changed plan")
+
+	pending := domain.PlanConsumer
+	require.NoError(t, repo.SaveSubscription(ctx, &domain.PlanSubscription{
+		UserID: user, Plan: domain.PlanPremium, PendingPlan: &pending,
+		PeriodStart: march, PeriodEnd: march.AddDate(0, 1, 0), UpdatedAt: march,
+	}))
+	sub, err = repo.GetSubscription(ctx, user)
+	require.NoError(t, err)
+	require.NotNil(t, sub)
+	assert.Equal(t, domain.PlanPremium, sub.Plan)
+	require.NotNil(t, sub.PendingPlan)
+	assert.Equal(t, domain.PlanConsumer, *sub.PendingPlan)
+
+	april := march.AddDate(0, 1, 0)
+	require.NoError(t, repo.SaveSubscription(ctx, &domain.PlanSubscription{
+		UserID: user, Plan: domain.PlanBusiness,
+		PeriodStart: april, PeriodEnd: april.AddDate(0, 1, 0), UpdatedAt: april,
+	}))
+	sub, err = repo.GetSubscription(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, domain.PlanBusiness, sub.Plan, "saving again replaces the subscription")
+	assert.Nil(t, sub.PendingPlan)
+	assert.True(t, sub.PeriodStart.Equal(april))
+}
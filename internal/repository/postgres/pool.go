@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/pkg/config"
+	"kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Connect opens the service's connection pool with its tuned limits. The
+// statement timeout, if any, is sent as the session statement_timeout so the
+// server aborts runaway statements even when the client has gone away, and
+// the query timeout becomes the deadline of instrumented repository calls
+// made without one. Sessions run in UTC so that now(), date_trunc and
+// timestamps read back agree with the services whatever the server's zone.
+func Connect(cfg config.DatabaseConfig) (*sqlx.DB, error) {
+	dsn := withRuntimeParam(withStatementTimeout(cfg.URL, cfg.StatementTimeout), "TimeZone", "UTC")
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to database")
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	queries.mu.Lock()
+	queries.timeout = cfg.QueryTimeout
+	queries.mu.Unlock()
+	return db, nil
+}
+
+// withStatementTimeout adds statement_timeout to a URL or key=value DSN
+// unless it already sets one; lib/pq forwards unknown settings to the
+// server as run-time parameters.
+func withStatementTimeout(dsn string, d time.Duration) string {
+	if d <= 0 {
+		return dsn
+	}
+	return withRuntimeParam(dsn, "statement_timeout", strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+// withRuntimeParam adds key=value to a URL or key=value DSN unless it
+// already sets key.
+func withRuntimeParam(dsn, key, value string) string {
+	if strings.Contains(strings.ToLower(dsn), strings.ToLower(key)+"=") {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return fmt.Sprintf("%s%s%s=%s", dsn, sep, key, value)
+	}
+	return fmt.Sprintf("%s %s=%s", dsn, key, value)
+}
+
+// PoolStats is a point-in-time view of a service's connection pool.
+type PoolStats struct {
+	Service           string  `json:"service"`
+	MaxOpen           int     `json:"max_open"`
+	Open              int     `json:"open"`
+	InUse             int     `json:"in_use"`
+	Idle              int     `json:"idle"`
+	WaitCount         int64   `json:"wait_count"`
+	WaitSeconds       float64 `json:"wait_seconds"`
+	MaxIdleClosed     int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64   `json:"max_lifetime_closed"`
+}
+
+// PoolStatsOf reads the current statistics of db.
+func PoolStatsOf(service string, db *sqlx.DB) PoolStats {
+	s := db.Stats()
+	return PoolStats{
+		Service:           service,
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitSeconds:       s.WaitDuration.Seconds(),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}
+}
+
+// PoolMonitor samples a pool periodically and warns when requests had to
+// wait for a connection, the first sign that the pool is undersized or that
+// long statements are holding connections.
+type PoolMonitor struct {
+	service  string
+	db       *sqlx.DB
+	interval time.Duration
+	logger   logger.Logger
+
+	last     PoolStats
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewPoolMonitor(service string, db *sqlx.DB, interval time.Duration, log logger.Logger) *PoolMonitor {
+	return &PoolMonitor{
+		service:  service,
+		db:       db,
+		interval: interval,
+		logger:   log,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (m *PoolMonitor) Start() {
+	m.last = PoolStatsOf(m.service, m.db)
+	ticker := time.NewTicker(m.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.sample()
+			}
+		}
+	}()
+}
+
+func (m *PoolMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+func (m *PoolMonitor) sample() {
+	cur := PoolStatsOf(m.service, m.db)
+	if waits := cur.WaitCount - m.last.WaitCount; waits > 0 {
+		waited := cur.WaitSeconds - m.last.WaitSeconds
+		m.logger.Warn("Database pool saturated", map[string]interface{}{
+			"service":      m.service,
+			"waits":        waits,
+			"mean_wait_ms": waited / float64(waits) * 1000,
+			"in_use":       cur.InUse,
+			"max_open":     cur.MaxOpen,
+			"interval_sec": m.interval.Seconds(),
+		})
+	}
+	m.last = cur
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStatementTimeout(t *testing.T) {
+	assert.Equal(t,
+		"postgres://u:p@db:5432/kyd?sslmode=disable&statement_timeout=30000",
+		withStatementTimeout("postgres://u:p@db:5432/kyd?sslmode=disable", 30*time.Second))
+	assert.Equal(t,
+		"postgres://u:p@db:5432/kyd?statement_timeout=1500",
+		withStatementTimeout("postgres://u:p@db:5432/kyd", 1500*time.Millisecond))
+	assert.Equal(t,
+		"host=db dbname=kyd statement_timeout=2000",
+		withStatementTimeout("host=db dbname=kyd", 2*time.Second))
+
+	// Disabled, or already set by the operator.
+	assert.Equal(t, "host=db", withStatementTimeout("host=db", 0))
+	assert.Equal(t, "host=db statement_timeout=10", withStatementTimeout("host=db statement_timeout=10", time.Second))
+}
+
+func TestWithRuntimeParam(t *testing.T) {
+	assert.Equal(t, "postgres://db/kyd?sslmode=disable&TimeZone=UTC", withRuntimeParam("postgres://db/kyd?sslmode=disable", "TimeZone", "UTC"))
+	assert.Equal(t, "host=db TimeZone=UTC", withRuntimeParam("host=db", "TimeZone", "UTC"))
+	assert.Equal(t, "host=db timezone=Africa/Blantyre", withRuntimeParam("host=db timezone=Africa/Blantyre", "TimeZone", "UTC"))
+}
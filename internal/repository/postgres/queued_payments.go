@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+)
+
+// Payments initiated after their corridor's cut-off wait in status
+// queued_for_next_window until metadata queued_until.
+
+func (r *TransactionRepository) FindDueQueued(ctx context.Context, now time.Time, limit int) ([]*domain.Transaction, error) {
+	txs := []*domain.Transaction{}
+	query := `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE status = $1 AND (metadata->>'queued_until')::timestamptz <= $2
+		ORDER BY (metadata->>'queued_until')::timestamptz, created_at
+		LIMIT $3
+	`
+	if err := r.db.SelectContext(ctx, &txs, query, domain.TransactionStatusQueued, now, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to find queued payments")
+	}
+	return txs, nil
+}
+
+// TransitionStatus moves a transaction out of status from, reporting false
+// when it was no longer in it.
+func (r *TransactionRepository) TransitionStatus(ctx context.Context, tx *domain.Transaction, from domain.TransactionStatus) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.transactions
+		SET status = $1, status_reason = $2, completed_at = $3, updated_at = $4
+		WHERE id = $5 AND status = $6
+	`, tx.Status, tx.StatusReason, tx.CompletedAt, tx.UpdatedAt, tx.ID, from)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update transaction status")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update transaction status")
+	}
+	return n == 1, nil
+}
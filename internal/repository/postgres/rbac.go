@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// RBACRepository keeps staff roles, what they permit and who holds them.
+type RBACRepository struct {
+	db *sqlx.DB
+}
+
+func NewRBACRepository(db *sqlx.DB) *RBACRepository {
+	return &RBACRepository{db: db}
+}
+
+func (r *RBACRepository) ListRoles(ctx context.Context) ([]domain.RoleDefinition, error) {
+	var rows []struct {
+		Name        domain.Role `db:"name"`
+		Description string      `db:"description"`
+		Permission  *string     `db:"permission"`
+	}
+	query := `
+		SELECT r.name, r.description, p.permission
+		FROM admin_schema.roles r
+		LEFT JOIN admin_schema.role_permissions p ON p.role = r.name
+		ORDER BY r.name, p.permission
+	`
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, errors.Wrap(err, "failed to list roles")
+	}
+
+	var roles []domain.RoleDefinition
+	for _, row := range rows {
+		if len(roles) == 0 || roles[len(roles)-1].Name != row.Name {
+			roles = append(roles, domain.RoleDefinition{Name: row.Name, Description: row.Description, Permissions: []domain.Permission{}})
+		}
+		if row.Permission != nil {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, domain.Permission(*row.Permission))
+		}
+	}
+	return roles, nil
+}
+
+func (r *RBACRepository) UserPermissions(ctx context.Context, userID uuid.UUID) ([]domain.Permission, error) {
+	var perms []domain.Permission
+	query := `
+		SELECT DISTINCT p.permission
+		FROM admin_schema.user_roles ur
+		JOIN admin_schema.role_permissions p ON p.role = ur.role
+		WHERE ur.user_id = $1
+	`
+	if err := r.db.SelectContext(ctx, &perms, query, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to get user permissions")
+	}
+	return perms, nil
+}
+
+func (r *RBACRepository) UserRoles(ctx context.Context, userID uuid.UUID) ([]domain.RoleAssignment, error) {
+	var roles []domain.RoleAssignment
+	query := `
+		SELECT user_id, role, granted_by, granted_at
+		FROM admin_schema.user_roles
+		WHERE user_id = $1
+		ORDER BY granted_at
+	`
+	if err := r.db.SelectContext(ctx, &roles, query, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to get user roles")
+	}
+	return roles, nil
+}
+
+// AssignRole stores the assignment and marks the account as an admin
+// account in one transaction.
+func (r *RBACRepository) AssignRole(ctx context.Context, a *domain.RoleAssignment) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO admin_schema.user_roles (user_id, role, granted_by, granted_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, role) DO NOTHING
+	`, a.UserID, a.Role, a.GrantedBy, a.GrantedAt); err != nil {
+		return errors.Wrap(err, "failed to assign role")
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.users SET user_type = 'admin', updated_at = NOW()
+		WHERE id = $1 AND user_type <> 'admin'
+	`, a.UserID); err != nil {
+		return errors.Wrap(err, "failed to mark user as admin")
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit role assignment")
+	}
+	return nil
+}
+
+// RevokeRole locks the admin role's holders before removing it, so two
+// admins revoking each other cannot leave nobody holding it.
+func (r *RBACRepository) RevokeRole(ctx context.Context, userID uuid.UUID, role domain.Role) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if role == domain.RoleAdmin {
+		var holders []uuid.UUID
+		if err := tx.SelectContext(ctx, &holders, `
+			SELECT user_id FROM admin_schema.user_roles WHERE role = $1 FOR UPDATE
+		`, domain.RoleAdmin); err != nil {
+			return false, errors.Wrap(err, "failed to lock admin role")
+		}
+		if len(holders) == 1 && holders[0] == userID {
+			return false, domain.ErrLastAdmin
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM admin_schema.user_roles WHERE user_id = $1 AND role = $2`, userID, role)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke role")
+	}
+	n, _ := res.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit role revocation")
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// reconciliationBreakLimit bounds how many breaks one check reports per
+// run, so a systemic problem cannot flood the table.
+const reconciliationBreakLimit = 1000
+
+// ReconciliationRepository runs the reconciliation checks' queries and
+// keeps the breaks they find.
+type ReconciliationRepository struct {
+	db *sqlx.DB
+}
+
+func NewReconciliationRepository(db *sqlx.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+// breaks runs a check query selecting subject, currency, expected, actual
+// and detail.
+func (r *ReconciliationRepository) breaks(ctx context.Context, check domain.ReconciliationCheck, query string, args ...interface{}) ([]domain.ReconciliationBreak, error) {
+	var found []domain.ReconciliationBreak
+	if err := r.db.SelectContext(ctx, &found, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to run reconciliation check "+string(check))
+	}
+	for i := range found {
+		found[i].Check = check
+	}
+	return found, nil
+}
+
+// WalletLedgerBreaks finds wallets whose ledger balance is not the sum of
+// their ledger entries' credits less their debits.
+func (r *ReconciliationRepository) WalletLedgerBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return r.breaks(ctx, domain.ReconcileWalletLedger, `
+		SELECT w.id::text AS subject, w.currency, COALESCE(e.total, 0) AS expected, w.ledger_balance AS actual,
+			format('wallet %s holds %s %s but its ledger entries sum to %s', w.wallet_address, w.ledger_balance, w.currency, COALESCE(e.total, 0)) AS detail
+		FROM customer_schema.wallets w
+		LEFT JOIN (
+			SELECT wallet_id, SUM(CASE WHEN entry_type = 'credit' THEN amount ELSE -amount END) AS total
+			FROM customer_schema.ledger_entries
+			GROUP BY wallet_id
+		) e ON e.wallet_id = w.id
+		WHERE w.ledger_balance <> COALESCE(e.total, 0)
+		ORDER BY w.id
+		LIMIT $1
+	`, reconciliationBreakLimit)
+}
+
+// WalletSplitBreaks finds wallets whose ledger balance is not their
+// available plus their reserved balance.
+func (r *ReconciliationRepository) WalletSplitBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return r.breaks(ctx, domain.ReconcileWalletSplit, `
+		SELECT w.id::text AS subject, w.currency, w.available_balance + w.reserved_balance AS expected, w.ledger_balance AS actual,
+			format('wallet %s has a ledger balance of %s %s but %s available and %s reserved', w.wallet_address, w.ledger_balance, w.currency, w.available_balance, w.reserved_balance) AS detail
+		FROM customer_schema.wallets w
+		WHERE w.ledger_balance <> w.available_balance + w.reserved_balance
+		ORDER BY w.id
+		LIMIT $1
+	`, reconciliationBreakLimit)
+}
+
+// GeneralLedgerBreaks finds currencies in which the customer_wallets
+// account differs from the ledger balances of wallets not owned by a house
+// user. Both sides are read in one statement, so a posting cannot land
+// between them.
+func (r *ReconciliationRepository) GeneralLedgerBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return r.breaks(ctx, domain.ReconcileGeneralLedger, `
+		WITH booked AS (
+			SELECT currency, SUM(credit) - SUM(debit) AS total
+			FROM customer_schema.journal_lines
+			WHERE account_code = $1
+			GROUP BY currency
+		), held AS (
+			SELECT w.currency, SUM(w.ledger_balance) AS total
+			FROM customer_schema.wallets w
+			WHERE NOT EXISTS (SELECT 1 FROM customer_schema.ledger_house_users h WHERE h.user_id = w.user_id)
+			GROUP BY w.currency
+		)
+		SELECT currency AS subject, currency, COALESCE(booked.total, 0) AS expected, COALESCE(held.total, 0) AS actual,
+			format('customer wallets hold %s %s but the customer_wallets account is %s', COALESCE(held.total, 0), currency, COALESCE(booked.total, 0)) AS detail
+		FROM booked FULL JOIN held USING (currency)
+		WHERE COALESCE(booked.total, 0) <> COALESCE(held.total, 0)
+		ORDER BY currency
+	`, domain.AccountCustomerWallets)
+}
+
+// TrialBalanceBreaks finds currencies in which the general ledger's debits
+// and credits differ.
+func (r *ReconciliationRepository) TrialBalanceBreaks(ctx context.Context) ([]domain.ReconciliationBreak, error) {
+	return r.breaks(ctx, domain.ReconcileTrialBalance, `
+		SELECT currency AS subject, currency, SUM(debit) AS expected, SUM(credit) AS actual,
+			format('general ledger debits of %s %s do not equal its credits of %s', SUM(debit), currency, SUM(credit)) AS detail
+		FROM customer_schema.journal_lines
+		GROUP BY currency
+		HAVING SUM(debit) <> SUM(credit)
+		ORDER BY currency
+	`)
+}
+
+// RecordBreaks stores what one run of check found, in one transaction:
+// breaks already open are refreshed, new ones are opened, and open breaks
+// of check the run did not see are resolved. It returns the breaks it
+// opened.
+func (r *ReconciliationRepository) RecordBreaks(ctx context.Context, check domain.ReconciliationCheck, found []domain.ReconciliationBreak, seenAt time.Time) ([]domain.ReconciliationBreak, int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	var opened []domain.ReconciliationBreak
+	for _, b := range found {
+		var rec domain.ReconciliationBreak
+		var inserted bool
+		row := tx.QueryRowxContext(ctx, `
+			INSERT INTO admin_schema.reconciliation_breaks (
+				id, kind, subject, currency, expected, actual, detail, status, first_seen_at, last_seen_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, 'open', $8, $8)
+			ON CONFLICT (kind, subject) WHERE status = 'open'
+			DO UPDATE SET expected = EXCLUDED.expected, actual = EXCLUDED.actual, detail = EXCLUDED.detail, last_seen_at = EXCLUDED.last_seen_at
+			RETURNING id, kind, subject, currency, expected, actual, detail, status, first_seen_at, last_seen_at, resolved_at, (xmax = 0) AS inserted
+		`, uuid.New(), check, b.Subject, b.Currency, b.Expected, b.Actual, b.Detail, seenAt)
+		if err := row.Scan(&rec.ID, &rec.Check, &rec.Subject, &rec.Currency, &rec.Expected, &rec.Actual, &rec.Detail,
+			&rec.Status, &rec.FirstSeenAt, &rec.LastSeenAt, &rec.ResolvedAt, &inserted); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to record reconciliation break")
+		}
+		if inserted {
+			opened = append(opened, rec)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_schema.reconciliation_breaks
+		SET status = 'resolved', resolved_at = $2
+		WHERE kind = $1 AND status = 'open' AND last_seen_at < $2
+	`, check, seenAt)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to resolve reconciliation breaks")
+	}
+	n, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to commit reconciliation breaks")
+	}
+	return opened, int(n), nil
+}
+
+// CountOpenBreaks returns how many breaks are open across all checks.
+func (r *ReconciliationRepository) CountOpenBreaks(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM admin_schema.reconciliation_breaks WHERE status = 'open'`)
+	return n, errors.Wrap(err, "failed to count reconciliation breaks")
+}
+
+// ListBreaks returns breaks, most recently seen first, optionally filtered
+// by check and status.
+func (r *ReconciliationRepository) ListBreaks(ctx context.Context, check domain.ReconciliationCheck, status domain.ReconciliationBreakStatus, limit, offset int) ([]domain.ReconciliationBreak, int, error) {
+	where := `WHERE ($1 = '' OR kind = $1) AND ($2 = '' OR status = $2)`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.reconciliation_breaks `+where, check, status); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count reconciliation breaks")
+	}
+	breaks := []domain.ReconciliationBreak{}
+	err := r.db.SelectContext(ctx, &breaks, `
+		SELECT id, kind, subject, currency, expected, actual, detail, status, first_seen_at, last_seen_at, resolved_at
+		FROM admin_schema.reconciliation_breaks `+where+`
+		ORDER BY last_seen_at DESC, first_seen_at DESC
+		LIMIT $3 OFFSET $4
+	`, check, status, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list reconciliation breaks")
+	}
+	return breaks, total, nil
+}
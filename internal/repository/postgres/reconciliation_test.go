@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewReconciliationRepository(db)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	t.Run("a break stays open while it is seen", func(t *testing.T) {
+		// A kind of its own, so real breaks are never resolved
+		check := domain.ReconciliationCheck("test_" + uuid.NewString()[:8])
+		t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.reconciliation_breaks WHERE kind = $1`, check) })
+		off := func(subject string, actual int64) domain.ReconciliationBreak {
+			return domain.ReconciliationBreak{Subject: subject, Currency: "MWK", Expected: decimal.NewFromInt(100), Actual: decimal.NewFromInt(actual)}
+		}
+
+		opened, resolved, err := repo.RecordBreaks(ctx, check, []domain.ReconciliationBreak{off("a", 90), off("b", 95)}, now)
+		require.NoError(t, err)
+		require.Len(t, opened, 2)
+		assert.Equal(t, 0, resolved)
+		assert.Equal(t, check, opened[0].Check)
+		assert.Equal(t, domain.ReconciliationBreakOpen, opened[0].Status)
+		assert.True(t, opened[0].FirstSeenAt.Equal(now))
+
+		opened, resolved, err = repo.RecordBreaks(ctx, check, []domain.ReconciliationBreak{off("a", 80)}, now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, opened, "a is still open")
+		assert.Equal(t, 1, resolved, "b was not seen")
+
+		open, total, err := repo.ListBreaks(ctx, check, domain.ReconciliationBreakOpen, 10, 0)
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		assert.Equal(t, "a", open[0].Subject)
+		assert.Equal(t, "-20", open[0].Difference().String(), "carries the latest amounts")
+		assert.True(t, open[0].FirstSeenAt.Equal(now))
+		assert.True(t, open[0].LastSeenAt.Equal(now.Add(time.Hour)))
+
+		resolvedBreaks, _, err := repo.ListBreaks(ctx, check, domain.ReconciliationBreakResolved, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, resolvedBreaks, 1)
+		assert.Equal(t, "b", resolvedBreaks[0].Subject)
+		require.NotNil(t, resolvedBreaks[0].ResolvedAt)
+		assert.True(t, resolvedBreaks[0].ResolvedAt.Equal(now.Add(time.Hour)))
+	})
+
+	t.Run("wallets that do not add up", func(t *testing.T) {
+		off := testWallet(t, db, testUser(t, db, now), domain.MWK, domain.WalletStatusActive)
+		_, err := db.Exec(`UPDATE customer_schema.wallets SET ledger_balance = 10 WHERE id = $1`, off)
+		require.NoError(t, err)
+		even := testWallet(t, db, testUser(t, db, now), domain.MWK, domain.WalletStatusActive)
+
+		ledger, err := repo.WalletLedgerBreaks(ctx)
+		require.NoError(t, err)
+		found := breaksBySubject(ledger)
+		require.Contains(t, found, off.String(), "no entries back the balance")
+		assert.True(t, found[off.String()].Expected.IsZero())
+		assert.True(t, found[off.String()].Actual.Equal(decimal.NewFromInt(10)))
+		assert.NotContains(t, found, even.String())
+
+		split, err := repo.WalletSplitBreaks(ctx)
+		require.NoError(t, err)
+		found = breaksBySubject(split)
+		require.Contains(t, found, off.String(), "nothing available or reserved")
+		assert.Equal(t, domain.ReconcileWalletSplit, found[off.String()].Check)
+		assert.NotContains(t, found, even.String())
+	})
+}
+
+func breaksBySubject(breaks []domain.ReconciliationBreak) map[string]domain.ReconciliationBreak {
+	bySubject := make(map[string]domain.ReconciliationBreak, len(breaks))
+	for _, b := range breaks {
+		bySubject[b.Subject] = b
+	}
+	return bySubject
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// RecoveryRepository finds stuck transactions for automated recovery and
+// keeps the exception queue of those it escalates.
+type RecoveryRepository struct {
+	db *sqlx.DB
+}
+
+func NewRecoveryRepository(db *sqlx.DB) *RecoveryRepository {
+	return &RecoveryRepository{db: db}
+}
+
+// FindStuck returns transactions in one of statuses that have not changed
+// since before, oldest first, with whether they reached the ledger and the
+// status of their settlement. Transactions with an open exception are left
+// to the admin handling it.
+func (r *RecoveryRepository) FindStuck(ctx context.Context, statuses []domain.TransactionStatus, before time.Time, limit int) ([]*domain.StuckTransaction, error) {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	stuck := []*domain.StuckTransaction{}
+	err := r.db.SelectContext(ctx, &stuck, `
+		SELECT`+transactionColumns+`, has_ledger_entries, settlement_status
+		FROM (
+			SELECT t.*,
+				EXISTS (SELECT 1 FROM customer_schema.ledger_entries e WHERE e.transaction_id = t.id) AS has_ledger_entries,
+				s.status AS settlement_status
+			FROM customer_schema.transactions t
+			LEFT JOIN customer_schema.settlements s ON s.id = t.settlement_id
+			WHERE t.status = ANY($1) AND t.updated_at < $2
+				AND NOT EXISTS (
+					SELECT 1 FROM admin_schema.transaction_exceptions x
+					WHERE x.transaction_id = t.id AND x.status = 'open'
+				)
+		) stuck
+		ORDER BY updated_at
+		LIMIT $3
+	`, pq.Array(names), before, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find stuck transactions")
+	}
+	return stuck, nil
+}
+
+// ReturnToSettlement hands a settling payment whose settlement does not
+// exist back to settlement batching. It reports false when the payment has
+// moved on or its settlement turned up.
+func (r *RecoveryRepository) ReturnToSettlement(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.transactions t
+		SET status = $2, settlement_id = NULL, updated_at = NOW()
+		WHERE t.id = $1 AND t.status = $3
+			AND NOT EXISTS (SELECT 1 FROM customer_schema.settlements s WHERE s.id = t.settlement_id)
+	`, id, domain.TransactionStatusPendingSettlement, domain.TransactionStatusSettling)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to return transaction to settlement")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to return transaction to settlement")
+	}
+	return n == 1, nil
+}
+
+// CreateException queues e for an admin, reporting false when the
+// transaction already has an open exception.
+func (r *RecoveryRepository) CreateException(ctx context.Context, e *domain.TransactionException) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.transaction_exceptions (id, transaction_id, reference, reason, detail, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (transaction_id) WHERE status = 'open' DO NOTHING
+	`, e.ID, e.TransactionID, e.Reference, e.Reason, e.Detail, e.Status, e.CreatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create transaction exception")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create transaction exception")
+	}
+	return n == 1, nil
+}
+
+// ListExceptions returns exceptions newest first, optionally filtered by
+// status and reason.
+func (r *RecoveryRepository) ListExceptions(ctx context.Context, status domain.TransactionExceptionStatus, reason domain.StuckReason, limit, offset int) ([]domain.TransactionException, int, error) {
+	where := `WHERE ($1 = '' OR status = $1) AND ($2 = '' OR reason = $2)`
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.transaction_exceptions `+where, status, reason); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count transaction exceptions")
+	}
+	exceptions := []domain.TransactionException{}
+	err := r.db.SelectContext(ctx, &exceptions, `
+		SELECT id, transaction_id, reference, reason, detail, status, resolution, resolved_by, resolved_at, created_at
+		FROM admin_schema.transaction_exceptions `+where+`
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, status, reason, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list transaction exceptions")
+	}
+	return exceptions, total, nil
+}
+
+// ResolveException closes an open exception with the admin's resolution.
+// It returns nil when there is no open exception with id.
+func (r *RecoveryRepository) ResolveException(ctx context.Context, id, adminID uuid.UUID, resolution string, at time.Time) (*domain.TransactionException, error) {
+	var e domain.TransactionException
+	err := r.db.GetContext(ctx, &e, `
+		UPDATE admin_schema.transaction_exceptions
+		SET status = 'resolved', resolution = $2, resolved_by = $3, resolved_at = $4
+		WHERE id = $1 AND status = 'open'
+		RETURNING id, transaction_id, reference, reason, detail, status, resolution, resolved_by, resolved_at, created_at
+	`, id, resolution, adminID, at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve transaction exception")
+	}
+	return &e, nil
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewRecoveryRepository(db)
+	// Long past, so no real transaction has been stuck longer
+	at := time.Date(1990, 3, 1, 9, 0, 0, 0, time.UTC)
+	user := testUser(t, db, at)
+	wallet := testWallet(t, db, user, domain.MWK, domain.WalletStatusActive)
+	inFlight := []domain.TransactionStatus{
+		domain.TransactionStatusPending, domain.TransactionStatusProcessing,
+		domain.TransactionStatusPendingSettlement, domain.TransactionStatusSettling,
+	}
+
+	submitted := testSettlement(t, db, 100, "submitted", nil)
+	posted := testStuck(t, db, user, wallet, domain.TransactionStatusPending, nil, at)
+	testEntry(t, db, posted, wallet, 100, 100, at)
+	settling := testStuck(t, db, user, wallet, domain.TransactionStatusSettling, &submitted, at.Add(time.Minute))
+	missing := uuid.New()
+	orphan := testStuck(t, db, user, wallet, domain.TransactionStatusSettling, &missing, at.Add(2*time.Minute))
+	escalated := testStuck(t, db, user, wallet, domain.TransactionStatusProcessing, nil, at)
+	testStuck(t, db, user, wallet, domain.TransactionStatusCompleted, nil, at)
+	testStuck(t, db, user, wallet, domain.TransactionStatusPending, nil, at.Add(2*time.Hour))
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM admin_schema.transaction_exceptions WHERE transaction_id IN ($1, $2)`, escalated, posted)
+	})
+	open := testException(escalated)
+	created, err := repo.CreateException(ctx, open)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	stuck, err := repo.FindStuck(ctx, inFlight, at.Add(time.Hour), 1000)
+	require.NoError(t, err)
+	var ids []uuid.UUID
+	byID := map[uuid.UUID]*domain.StuckTransaction{}
+	for _, st := range stuck {
+		if st.SenderID == user {
+			ids = append(ids, st.ID)
+			byID[st.ID] = st
+		}
+	}
+	assert.Equal(t, []uuid.UUID{posted, settling, orphan}, ids, "oldest first, leaving escalated, finished and recent ones")
+	if len(ids) == 3 {
+		assert.True(t, byID[posted].HasLedgerEntries)
+		assert.False(t, byID[settling].HasLedgerEntries)
+		require.NotNil(t, byID[settling].SettlementStatus)
+		assert.Equal(t, domain.SettlementStatusSubmitted, *byID[settling].SettlementStatus)
+		assert.Nil(t, byID[orphan].SettlementStatus, "its settlement does not exist")
+	}
+
+	t.Run("back to settlement batching", func(t *testing.T) {
+		returned, err := repo.ReturnToSettlement(ctx, orphan)
+		require.NoError(t, err)
+		assert.True(t, returned)
+		returned, err = repo.ReturnToSettlement(ctx, orphan)
+		require.NoError(t, err)
+		assert.False(t, returned, "moved on")
+		returned, err = repo.ReturnToSettlement(ctx, settling)
+		require.NoError(t, err)
+		assert.False(t, returned, "its settlement exists")
+
+		var status domain.TransactionStatus
+		var settlement *uuid.UUID
+		require.NoError(t, db.QueryRow(`SELECT status, settlement_id FROM customer_schema.transactions WHERE id = $1`, orphan).Scan(&status, &settlement))
+		assert.Equal(t, domain.TransactionStatusPendingSettlement, status)
+		assert.Nil(t, settlement)
+	})
+
+	t.Run("one open exception per transaction", func(t *testing.T) {
+		created, err := repo.CreateException(ctx, testException(escalated))
+		require.NoError(t, err)
+		assert.False(t, created)
+
+		other := testException(posted)
+		other.Reason = domain.StuckMissingLedgerEntry
+		created, err = repo.CreateException(ctx, other)
+		require.NoError(t, err)
+		require.True(t, created)
+		listed, total, err := repo.ListExceptions(ctx, domain.TransactionExceptionOpen, domain.StuckPartnerTimeout, 1000, 0)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, total, 1)
+		assert.Equal(t, []uuid.UUID{open.ID}, exceptionIDs(listed, open.ID, other.ID), "filtered by reason")
+
+		admin := uuid.New()
+		e, err := repo.ResolveException(ctx, open.ID, admin, "completed manually", at)
+		require.NoError(t, err)
+		require.NotNil(t, e)
+		assert.Equal(t, domain.TransactionExceptionResolved, e.Status)
+		assert.Equal(t, admin, *e.ResolvedBy)
+		e, err = repo.ResolveException(ctx, open.ID, admin, "again", at)
+		require.NoError(t, err)
+		assert.Nil(t, e, "no longer open")
+
+		listed, _, err = repo.ListExceptions(ctx, domain.TransactionExceptionOpen, "", 1000, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{other.ID}, exceptionIDs(listed, open.ID, other.ID))
+		created, err = repo.CreateException(ctx, testException(escalated))
+		require.NoError(t, err)
+		assert.True(t, created, "escalated again once resolved")
+	})
+}
+
+// testStuck adds a payment in status last updated at updatedAt. It goes
+// with the user.
+func testStuck(t *testing.T, db *sqlx.DB, userID, walletID uuid.UUID, status domain.TransactionStatus, settlementID *uuid.UUID, updatedAt time.Time) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO customer_schema.transactions (
+			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			amount, currency, exchange_rate, converted_amount, converted_currency, net_amount,
+			status, transaction_type, settlement_id, created_at, updated_at
+		) VALUES ($1, $2, $3, $3, $4, $4, 100, 'MWK', 1, 100, 'MWK', 100, $5, 'payment', $6, $7, $7)
+	`, id, "TEST-"+id.String(), userID, walletID, status, settlementID, updatedAt)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.transactions WHERE id = $1`, id) })
+	return id
+}
+
+// testException is an unsaved open partner timeout of txID. It is dated
+// far ahead, so it is listed before any real exception.
+func testException(txID uuid.UUID) *domain.TransactionException {
+	return &domain.TransactionException{
+		ID:            uuid.New(),
+		TransactionID: txID,
+		Reference:     "TEST-" + txID.String(),
+		Reason:        domain.StuckPartnerTimeout,
+		Detail:        "test",
+		Status:        domain.TransactionExceptionOpen,
+		CreatedAt:     time.Date(2990, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// exceptionIDs lists the IDs of exceptions among ids, in order.
+func exceptionIDs(exceptions []domain.TransactionException, ids ...uuid.UUID) []uuid.UUID {
+	var out []uuid.UUID
+	for _, e := range exceptions {
+		for _, id := range ids {
+			if e.ID == id {
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/export"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// settlementReportFilters limits the settlement report to completed
+// transactions on transactions aliased as t.
+func settlementReportFilters(f domain.ExportFilters) func(add func(string, interface{})) {
+	return func(add func(string, interface{})) {
+		add("t.status = $%d", string(domain.TransactionStatusCompleted))
+		if c := strings.TrimSpace(f.Currency); c != "" {
+			add("t.currency = $%d", c)
+		}
+	}
+}
+
+const settlementReportUser = "(t.sender_id = $%[1]d OR t.receiver_id = $%[1]d)"
+
+func (r *TransactionRepository) CountSettlementReport(ctx context.Context, f domain.ExportFilters) (int, error) {
+	where, args := exportWhere("t.", f, nil, settlementReportUser, settlementReportFilters(f))
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.transactions t `+where, args...); err != nil {
+		return 0, errors.Wrap(err, "failed to count settlement report")
+	}
+	return total, nil
+}
+
+// SettlementReport returns completed transactions in ascending
+// (created_at, id) order with their settlement batch.
+func (r *TransactionRepository) SettlementReport(ctx context.Context, q export.Query) ([]*domain.SettlementReportRow, error) {
+	where, args := exportWhere("t.", q.Filters, q.After, settlementReportUser, settlementReportFilters(q.Filters))
+	args = append(args, q.Limit)
+	query := `
+		SELECT
+			t.id, t.reference, t.sender_id, t.receiver_id, t.amount, t.currency,
+			t.converted_amount, t.converted_currency, t.fee_amount, COALESCE(t.fee_currency, '') AS fee_currency,
+			COALESCE(t.net_amount, t.converted_amount) AS net_amount, t.settlement_id,
+			COALESCE(s.batch_reference, '') AS settlement_batch, COALESCE(s.status, '') AS settlement_status,
+			COALESCE(s.network, '') AS settlement_network, COALESCE(t.blockchain_tx_hash, '') AS blockchain_tx_hash,
+			t.created_at, t.completed_at
+		FROM customer_schema.transactions t
+		LEFT JOIN customer_schema.settlements s ON s.id = t.settlement_id
+		` + where + `
+		ORDER BY t.created_at, t.id
+		LIMIT $` + fmt.Sprint(len(args))
+
+	var rows []*domain.SettlementReportRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to export settlement report")
+	}
+	return rows, nil
+}
+
+// feeReportFilters limits the fee report to payments that carried a fee.
+func feeReportFilters(f domain.ExportFilters) func(add func(string, interface{})) {
+	return func(add func(string, interface{})) {
+		add("fee_amount > $%d", 0)
+		if c := strings.TrimSpace(f.Currency); c != "" {
+			add("currency = $%d", c)
+		}
+	}
+}
+
+func (r *TransactionRepository) CountFeeReport(ctx context.Context, f domain.ExportFilters) (int, error) {
+	where, args := exportWhere("", f, nil, "sender_id = $%d", feeReportFilters(f))
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM customer_schema.transactions `+where, args...); err != nil {
+		return 0, errors.Wrap(err, "failed to count fee report")
+	}
+	return total, nil
+}
+
+// FeeReport returns the account's fee-bearing payments in ascending
+// (created_at, id) order.
+func (r *TransactionRepository) FeeReport(ctx context.Context, q export.Query) ([]*domain.Transaction, error) {
+	where, args := exportWhere("", q.Filters, q.After, "sender_id = $%d", feeReportFilters(q.Filters))
+	args = append(args, q.Limit)
+	query := `
+		SELECT
+			id, reference, sender_id, receiver_id, amount, currency, fee_amount,
+			COALESCE(fee_currency, '') AS fee_currency, status, transaction_type, created_at
+		FROM customer_schema.transactions
+		` + where + `
+		ORDER BY created_at, id
+		LIMIT $` + fmt.Sprint(len(args))
+
+	var txs []*domain.Transaction
+	if err := r.db.SelectContext(ctx, &txs, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to export fee report")
+	}
+	return txs, nil
+}
+
+const reportScheduleColumns = `id, owner_id, kind, currency, last_period, created_by, created_at`
+
+func (r *ExportJobRepository) CreateReportSchedule(ctx context.Context, sch *domain.ReportSchedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.report_schedules (`+reportScheduleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, sch.ID, sch.OwnerID, sch.Kind, sch.Currency, sch.LastPeriod, sch.CreatedBy, sch.CreatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return export.ErrScheduleExists
+	}
+	return errors.Wrap(err, "failed to create report schedule")
+}
+
+func (r *ExportJobRepository) ListReportSchedules(ctx context.Context, owner *uuid.UUID) ([]domain.ReportSchedule, error) {
+	schedules := []domain.ReportSchedule{}
+	query := `SELECT ` + reportScheduleColumns + ` FROM admin_schema.report_schedules`
+	var args []interface{}
+	if owner != nil {
+		query += ` WHERE owner_id = $1`
+		args = append(args, *owner)
+	}
+	query += ` ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &schedules, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to list report schedules")
+	}
+	return schedules, nil
+}
+
+func (r *ExportJobRepository) DeleteReportSchedule(ctx context.Context, id uuid.UUID, owner *uuid.UUID) (bool, error) {
+	query := `DELETE FROM admin_schema.report_schedules WHERE id = $1`
+	args := []interface{}{id}
+	if owner != nil {
+		query += ` AND owner_id = $2`
+		args = append(args, *owner)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete report schedule")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *ExportJobRepository) ListDueReportSchedules(ctx context.Context, period time.Time, limit int) ([]domain.ReportSchedule, error) {
+	schedules := []domain.ReportSchedule{}
+	err := r.db.SelectContext(ctx, &schedules, `
+		SELECT `+reportScheduleColumns+` FROM admin_schema.report_schedules
+		WHERE last_period IS NULL OR last_period < $1::date
+		ORDER BY created_at
+		LIMIT $2
+	`, period, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list due report schedules")
+	}
+	return schedules, nil
+}
+
+func (r *ExportJobRepository) QueueScheduledReport(ctx context.Context, scheduleID uuid.UUID, period time.Time, j *domain.ExportJob) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_schema.report_schedules SET last_period = $2::date
+		WHERE id = $1 AND (last_period IS NULL OR last_period < $2::date)
+	`, scheduleID, period)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to claim report schedule")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, insertExportJob, exportJobArgs(j)...); err != nil {
+		return false, errors.Wrap(err, "failed to queue scheduled report")
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit scheduled report")
+	}
+	return true, nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RiskBacktestRepository struct {
+	db *sqlx.DB
+}
+
+func NewRiskBacktestRepository(db *sqlx.DB) *RiskBacktestRepository {
+	return &RiskBacktestRepository{db: db}
+}
+
+const riskBacktestColumns = `id, window_from, window_to, baseline, candidate, note, status, processed, results, error,
+	requested_by, started_at, completed_at, created_at, updated_at`
+
+func (r *RiskBacktestRepository) Create(ctx context.Context, b *domain.RiskBacktest) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.risk_backtests (`+riskBacktestColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`, b.ID, b.From, b.To, b.Baseline, b.Candidate, b.Note, b.Status, b.Processed, b.Results, b.Error,
+		b.RequestedBy, b.StartedAt, b.CompletedAt, b.CreatedAt, b.UpdatedAt)
+	return errors.Wrap(err, "failed to create risk backtest")
+}
+
+func (r *RiskBacktestRepository) Get(ctx context.Context, id uuid.UUID) (*domain.RiskBacktest, error) {
+	var b domain.RiskBacktest
+	err := r.db.GetContext(ctx, &b, `SELECT `+riskBacktestColumns+` FROM admin_schema.risk_backtests WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get risk backtest")
+	}
+	return &b, nil
+}
+
+func (r *RiskBacktestRepository) List(ctx context.Context, limit, offset int) ([]domain.RiskBacktest, int, error) {
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.risk_backtests`); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count risk backtests")
+	}
+	backtests := []domain.RiskBacktest{}
+	err := r.db.SelectContext(ctx, &backtests, `
+		SELECT `+riskBacktestColumns+` FROM admin_schema.risk_backtests
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list risk backtests")
+	}
+	return backtests, total, nil
+}
+
+func (r *RiskBacktestRepository) Claim(ctx context.Context, now, staleBefore time.Time) (*domain.RiskBacktest, error) {
+	var b domain.RiskBacktest
+	err := r.db.GetContext(ctx, &b, `
+		UPDATE admin_schema.risk_backtests
+		SET status = 'running', started_at = COALESCE(started_at, $1), updated_at = $1
+		WHERE id = (
+			SELECT id FROM admin_schema.risk_backtests
+			WHERE status = 'queued' OR (status = 'running' AND updated_at < $2)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+riskBacktestColumns,
+		now, staleBefore)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim risk backtest")
+	}
+	return &b, nil
+}
+
+func (r *RiskBacktestRepository) Update(ctx context.Context, b *domain.RiskBacktest) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.risk_backtests SET
+			status = $2, processed = $3, results = $4, error = $5, completed_at = $6, updated_at = $7
+		WHERE id = $1
+	`, b.ID, b.Status, b.Processed, b.Results, b.Error, b.CompletedAt, b.UpdatedAt)
+	return errors.Wrap(err, "failed to update risk backtest")
+}
+
+func (r *RiskBacktestRepository) Payments(ctx context.Context, from, to, afterTime time.Time, afterID uuid.UUID, limit int) ([]domain.RiskBacktestPayment, error) {
+	payments := []domain.RiskBacktestPayment{}
+	err := r.db.SelectContext(ctx, &payments, `
+		SELECT t.id, t.reference, t.sender_id, t.amount, t.currency, t.status, t.created_at,
+			COALESCE(u.kyc_level, 0) AS kyc_level, u.created_at AS sender_created_at
+		FROM customer_schema.transactions t
+		JOIN customer_schema.users u ON u.id = t.sender_id
+		WHERE t.transaction_type = 'payment'
+		  AND t.created_at >= $1 AND t.created_at < $2
+		  AND (t.created_at, t.id) > ($3, $4)
+		ORDER BY t.created_at, t.id
+		LIMIT $5
+	`, from, to, afterTime, afterID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list payments to backtest")
+	}
+	return payments, nil
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskBacktestRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewRiskBacktestRepository(db)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	t.Run("claims queued and stale backtests", func(t *testing.T) {
+		// Older than any real backtest, so claimed before them
+		busy := testBacktest(t, db, repo, domain.RiskBacktestRunning, now.Add(-2*time.Hour), now.Add(-time.Minute))
+		queued := testBacktest(t, db, repo, domain.RiskBacktestQueued, now.Add(-time.Hour), now.Add(-time.Hour))
+
+		b, err := repo.Claim(ctx, now, now.Add(-5*time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, b)
+		assert.Equal(t, queued.ID, b.ID, "the older one is still within its lease")
+		assert.Equal(t, domain.RiskBacktestRunning, b.Status)
+		require.NotNil(t, b.StartedAt)
+		assert.True(t, b.StartedAt.Equal(now))
+
+		later := now.Add(10 * time.Minute)
+		b, err = repo.Claim(ctx, later, later.Add(-5*time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, b)
+		assert.Equal(t, busy.ID, b.ID, "taken over")
+		assert.True(t, b.StartedAt.Equal(*busy.StartedAt), "keeps when it first started")
+		assert.True(t, b.UpdatedAt.Equal(later))
+
+		msg := "test"
+		b.Status, b.Error, b.CompletedAt, b.UpdatedAt = domain.RiskBacktestFailed, &msg, &later, later
+		require.NoError(t, repo.Update(ctx, b))
+		got, err := repo.Get(ctx, busy.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.RiskBacktestFailed, got.Status)
+		assert.Equal(t, "test", *got.Error)
+		assert.Equal(t, int64(100000), got.Candidate.HighValueThreshold, "rules round-trip")
+	})
+
+	t.Run("pages through the window", func(t *testing.T) {
+		sender := testUser(t, db, now.AddDate(-1, 0, 0))
+		wallet := testWallet(t, db, sender, domain.MWK, domain.WalletStatusActive)
+		payment := func(at time.Time) uuid.UUID {
+			id := testCredit(t, db, sender, wallet, 100, domain.TransactionStatusCompleted, "", at)
+			_, err := db.Exec(`UPDATE customer_schema.transactions SET created_at = $2 WHERE id = $1`, id, at)
+			require.NoError(t, err)
+			return id
+		}
+		first, tied := payment(now), payment(now)
+		if tied.String() < first.String() {
+			first, tied = tied, first
+		}
+		last := payment(now.Add(time.Minute))
+		payment(now.Add(time.Hour))
+
+		page, err := repo.Payments(ctx, now, now.Add(time.Hour), time.Time{}, uuid.Nil, 2)
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, []uuid.UUID{first, tied}, []uuid.UUID{page[0].ID, page[1].ID}, "ties in id order")
+		assert.Equal(t, sender, page[0].SenderID)
+		assert.True(t, page[0].SenderCreatedAt.Equal(now.AddDate(-1, 0, 0)))
+
+		page, err = repo.Payments(ctx, now, now.Add(time.Hour), page[1].CreatedAt, page[1].ID, 2)
+		require.NoError(t, err)
+		require.Len(t, page, 1, "the end of the window is excluded")
+		assert.Equal(t, last, page[0].ID)
+	})
+}
+
+// testBacktest adds a backtest created at created and last updated at
+// updated, and removes it after the test.
+func testBacktest(t *testing.T, db *sqlx.DB, repo *RiskBacktestRepository, status domain.RiskBacktestStatus, created, updated time.Time) *domain.RiskBacktest {
+	t.Helper()
+	rules := domain.RiskRules{KYCAmountLimits: map[int]int64{0: 1000}, HighValueThreshold: 100000, BlockScore: 80}
+	b := &domain.RiskBacktest{
+		ID:          uuid.New(),
+		From:        created.Add(-24 * time.Hour),
+		To:          created,
+		Baseline:    rules,
+		Candidate:   rules,
+		Note:        "test",
+		Status:      status,
+		RequestedBy: uuid.New(),
+		CreatedAt:   created,
+		UpdatedAt:   updated,
+	}
+	if status == domain.RiskBacktestRunning {
+		b.StartedAt = &created
+	}
+	require.NoError(t, repo.Create(context.Background(), b))
+	t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.risk_backtests WHERE id = $1`, b.ID) })
+	return b
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // SecurityRepository persists security events, blocklist entries, and health snapshots.
@@ -81,6 +82,9 @@ func buildSecurityEventsWhere(filter *security.EventFilter) (string, []interface
 	if filter.Type != nil && strings.TrimSpace(*filter.Type) != "" {
 		add("event_type = $%d", strings.TrimSpace(*filter.Type))
 	}
+	if len(filter.Types) > 0 {
+		add("event_type = ANY($%d)", pq.Array(filter.Types))
+	}
 	if filter.Severity != nil && strings.TrimSpace(*filter.Severity) != "" {
 		add("severity = $%d", strings.TrimSpace(*filter.Severity))
 	}
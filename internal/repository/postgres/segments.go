@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/segments"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+type SegmentRepository struct {
+	db *sqlx.DB
+}
+
+func NewSegmentRepository(db *sqlx.DB) *SegmentRepository {
+	return &SegmentRepository{db: db}
+}
+
+const segmentColumns = `id, key, name, description, criteria, fee_rate, risk_adjustment, created_by, created_at, updated_at`
+
+// buildSegmentWhere turns segment criteria into conditions on
+// customer_schema.users aliased as u. Only active, non-admin accounts are
+// ever matched. It must agree with domain.SegmentCriteria.Matches.
+func buildSegmentWhere(c domain.SegmentCriteria, args []interface{}) (string, []interface{}) {
+	clauses := []string{"u.is_active = TRUE", "u.user_status = 'active'", "u.user_type <> 'admin'"}
+	add := func(clause string, value interface{}) {
+		args = append(args, value)
+		clauses = append(clauses, fmt.Sprintf(clause, len(args)))
+	}
+	if len(c.Countries) > 0 {
+		add("u.country_code = ANY($%d)", pq.Array(c.Countries))
+	}
+	if len(c.KYCLevels) > 0 {
+		levels := make([]int64, len(c.KYCLevels))
+		for i, l := range c.KYCLevels {
+			levels[i] = int64(l)
+		}
+		add("u.kyc_level = ANY($%d)", pq.Array(levels))
+	}
+	if len(c.UserTypes) > 0 {
+		types := make([]string, len(c.UserTypes))
+		for i, t := range c.UserTypes {
+			types[i] = string(t)
+		}
+		add("u.user_type = ANY($%d)", pq.Array(types))
+	}
+	if c.ActiveWithinDays > 0 {
+		add("u.last_login >= NOW() - make_interval(days => $%d)", c.ActiveWithinDays)
+	}
+	if c.MinAccountAgeDays > 0 {
+		add("u.created_at <= NOW() - make_interval(days => $%d)", c.MinAccountAgeDays)
+	}
+	if c.MaxAccountAgeDays > 0 {
+		add("u.created_at > NOW() - make_interval(days => $%d)", c.MaxAccountAgeDays)
+	}
+	if c.NeedsVolume() {
+		args = append(args, c.VolumeCurrency, int(domain.SegmentVolumeWindow.Hours()))
+		volume := fmt.Sprintf(`(
+			SELECT COALESCE(SUM(t.amount), 0) FROM customer_schema.transactions t
+			WHERE t.sender_id = u.id AND t.currency = $%d AND t.status = 'completed'
+			  AND t.created_at >= NOW() - make_interval(hours => $%d)
+		)`, len(args)-1, len(args))
+		if c.MinVolume != nil {
+			add(volume+" >= $%d", *c.MinVolume)
+		}
+		if c.MaxVolume != nil {
+			add(volume+" <= $%d", *c.MaxVolume)
+		}
+	}
+	return "WHERE " + strings.Join(clauses, " AND "), args
+}
+
+func (r *SegmentRepository) CreateSegment(ctx context.Context, seg *domain.Segment) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.segments (`+segmentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, seg.ID, seg.Key, seg.Name, seg.Description, seg.Criteria, seg.FeeRate, seg.RiskAdjustment, seg.CreatedBy, seg.CreatedAt, seg.UpdatedAt)
+	return segmentWriteError(err, "failed to create segment")
+}
+
+func (r *SegmentRepository) UpdateSegment(ctx context.Context, seg *domain.Segment) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.segments
+		SET key = $2, name = $3, description = $4, criteria = $5, fee_rate = $6, risk_adjustment = $7, updated_at = $8
+		WHERE id = $1
+	`, seg.ID, seg.Key, seg.Name, seg.Description, seg.Criteria, seg.FeeRate, seg.RiskAdjustment, seg.UpdatedAt)
+	return segmentWriteError(err, "failed to update segment")
+}
+
+func segmentWriteError(err error, msg string) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return segments.ErrSegmentExists
+	}
+	return errors.Wrap(err, msg)
+}
+
+func (r *SegmentRepository) DeleteSegment(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.segments WHERE id = $1`, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete segment")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *SegmentRepository) ListSegments(ctx context.Context) ([]domain.Segment, error) {
+	segs := []domain.Segment{}
+	if err := r.db.SelectContext(ctx, &segs, `SELECT `+segmentColumns+` FROM admin_schema.segments ORDER BY key`); err != nil {
+		return nil, errors.Wrap(err, "failed to list segments")
+	}
+	return segs, nil
+}
+
+func (r *SegmentRepository) CountMembers(ctx context.Context, criteria domain.SegmentCriteria) (int, error) {
+	where, args := buildSegmentWhere(criteria, nil)
+	var n int
+	if err := r.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM customer_schema.users u `+where, args...); err != nil {
+		return 0, errors.Wrap(err, "failed to count segment members")
+	}
+	return n, nil
+}
+
+func (r *SegmentRepository) GetSubject(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	var u domain.User
+	err := r.db.GetContext(ctx, &u, `
+		SELECT id, user_type, kyc_level, country_code, is_active, user_status, last_login, created_at
+		FROM customer_schema.users WHERE id = $1
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load segment subject")
+	}
+	return &u, nil
+}
+
+func (r *SegmentRepository) SentVolume(ctx context.Context, userID uuid.UUID, currency domain.Currency, since time.Time) (decimal.Decimal, error) {
+	var total decimal.Decimal
+	err := r.db.GetContext(ctx, &total, `
+		SELECT COALESCE(SUM(amount), 0) FROM customer_schema.transactions
+		WHERE sender_id = $1 AND currency = $2 AND status = 'completed' AND created_at >= $3
+	`, userID, currency, since)
+	if err != nil {
+		return decimal.Zero, errors.Wrap(err, "failed to sum sent volume")
+	}
+	return total, nil
+}
+
+func (r *SegmentRepository) ListFlags(ctx context.Context) ([]domain.FeatureFlag, error) {
+	flags := []domain.FeatureFlag{}
+	err := r.db.SelectContext(ctx, &flags, `
+		SELECT key, description, enabled, segments, updated_by, updated_at
+		FROM admin_schema.feature_flags ORDER BY key
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list feature flags")
+	}
+	return flags, nil
+}
+
+func (r *SegmentRepository) UpsertFlag(ctx context.Context, f *domain.FeatureFlag) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.feature_flags (key, description, enabled, segments, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET
+			description = EXCLUDED.description, enabled = EXCLUDED.enabled, segments = EXCLUDED.segments,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`, f.Key, f.Description, f.Enabled, f.Segments, f.UpdatedBy, f.UpdatedAt)
+	return errors.Wrap(err, "failed to save feature flag")
+}
+
+func (r *SegmentRepository) DeleteFlag(ctx context.Context, key string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.feature_flags WHERE key = $1`, key)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete feature flag")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/segments"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewSegmentRepository(db)
+	now := time.Now().UTC()
+
+	t.Run("keys are unique", func(t *testing.T) {
+		seg := testSegment(t, db, repo, domain.SegmentCriteria{Countries: []string{"MW"}})
+		dup := newTestSegment(domain.SegmentCriteria{})
+		dup.Key = seg.Key
+		assert.ErrorIs(t, repo.CreateSegment(ctx, dup), segments.ErrSegmentExists)
+
+		other := testSegment(t, db, repo, domain.SegmentCriteria{})
+		other.Key = seg.Key
+		assert.ErrorIs(t, repo.UpdateSegment(ctx, other), segments.ErrSegmentExists)
+
+		all, err := repo.ListSegments(ctx)
+		require.NoError(t, err)
+		var saved *domain.Segment
+		for i := range all {
+			if all[i].ID == seg.ID {
+				saved = &all[i]
+			}
+		}
+		require.NotNil(t, saved)
+		assert.Equal(t, []string{"MW"}, saved.Criteria.Countries)
+		assert.True(t, saved.FeeRate.Equal(*seg.FeeRate))
+
+		deleted, err := repo.DeleteSegment(ctx, seg.ID)
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		deleted, err = repo.DeleteSegment(ctx, seg.ID)
+		require.NoError(t, err)
+		assert.False(t, deleted)
+	})
+
+	t.Run("members in SQL are the members in process", func(t *testing.T) {
+		fresh := testUser(t, db, now.Add(-2*24*time.Hour))
+		veteran := testUser(t, db, now.Add(-400*24*time.Hour))
+		wallet := testWallet(t, db, veteran, domain.MWK, domain.WalletStatusActive)
+		testCredit(t, db, veteran, wallet, 1_500_000, domain.TransactionStatusCompleted, "", now)
+		testCredit(t, db, veteran, wallet, 600_000, domain.TransactionStatusCompleted, "", now)
+		testCredit(t, db, veteran, wallet, 5_000_000, domain.TransactionStatusFailed, "", now)
+		suspended := testUser(t, db, now)
+		_, err := db.Exec(`UPDATE customer_schema.users SET user_status = 'suspended' WHERE id = $1`, suspended)
+		require.NoError(t, err)
+		users := []uuid.UUID{fresh, veteran, suspended}
+
+		volume, err := repo.SentVolume(ctx, veteran, domain.MWK, now.Add(-domain.SegmentVolumeWindow))
+		require.NoError(t, err)
+		assert.True(t, volume.Equal(decimal.NewFromInt(2_100_000)), "completed payments only")
+
+		million := decimal.NewFromInt(1_000_000)
+		tests := []struct {
+			name     string
+			criteria domain.SegmentCriteria
+			want     []uuid.UUID
+		}{
+			{"everyone active", domain.SegmentCriteria{}, []uuid.UUID{fresh, veteran}},
+			{"country", domain.SegmentCriteria{Countries: []string{"ZM"}}, nil},
+			{"new accounts", domain.SegmentCriteria{MaxAccountAgeDays: 30}, []uuid.UUID{fresh}},
+			{"old accounts", domain.SegmentCriteria{MinAccountAgeDays: 365}, []uuid.UUID{veteran}},
+			{"recently active", domain.SegmentCriteria{ActiveWithinDays: 7}, []uuid.UUID{fresh}},
+			{"high volume", domain.SegmentCriteria{VolumeCurrency: domain.MWK, MinVolume: &million}, []uuid.UUID{veteran}},
+			{"low volume", domain.SegmentCriteria{VolumeCurrency: domain.MWK, MaxVolume: &million}, []uuid.UUID{fresh}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				where, args := buildSegmentWhere(tt.criteria, []interface{}{pq.Array(users)})
+				var inSQL []uuid.UUID
+				require.NoError(t, db.Select(&inSQL, `SELECT u.id FROM customer_schema.users u `+where+` AND u.id = ANY($1)`, args...))
+				assert.ElementsMatch(t, tt.want, inSQL)
+
+				var inProcess []uuid.UUID
+				for _, id := range users {
+					u, err := repo.GetSubject(ctx, id)
+					require.NoError(t, err)
+					volume := decimal.Zero
+					if tt.criteria.NeedsVolume() {
+						volume, err = repo.SentVolume(ctx, id, tt.criteria.VolumeCurrency, now.Add(-domain.SegmentVolumeWindow))
+						require.NoError(t, err)
+					}
+					if tt.criteria.Matches(u, volume, now) {
+						inProcess = append(inProcess, id)
+					}
+				}
+				assert.ElementsMatch(t, tt.want, inProcess)
+			})
+		}
+
+		u, err := repo.GetSubject(ctx, uuid.New())
+		require.NoError(t, err)
+		assert.Nil(t, u, "unknown users are in no segment")
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		seg := testSegment(t, db, repo, domain.SegmentCriteria{})
+		key := "test-" + uuid.NewString()[:8]
+		t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.feature_flags WHERE key = $1`, key) })
+		f := &domain.FeatureFlag{Key: key, Enabled: true, Segments: []string{seg.ID.String()}, UpdatedAt: now}
+		require.NoError(t, repo.UpsertFlag(ctx, f))
+		f.Enabled, f.Segments = false, []string{}
+		require.NoError(t, repo.UpsertFlag(ctx, f), "replaces the flag")
+
+		flags, err := repo.ListFlags(ctx)
+		require.NoError(t, err)
+		var saved *domain.FeatureFlag
+		for i := range flags {
+			if flags[i].Key == key {
+				saved = &flags[i]
+			}
+		}
+		require.NotNil(t, saved)
+		assert.False(t, saved.Enabled)
+		assert.Empty(t, saved.Segments)
+
+		deleted, err := repo.DeleteFlag(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		deleted, err = repo.DeleteFlag(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, deleted)
+	})
+}
+
+func newTestSegment(criteria domain.SegmentCriteria) *domain.Segment {
+	id := uuid.New()
+	rate := decimal.RequireFromString("0.008")
+	return &domain.Segment{
+		ID:        id,
+		Key:       "test-" + id.String()[:8],
+		Name:      "Test segment",
+		Criteria:  criteria,
+		FeeRate:   &rate,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// testSegment adds a segment and removes it after the test.
+func testSegment(t *testing.T, db *sqlx.DB, repo *SegmentRepository, criteria domain.SegmentCriteria) *domain.Segment {
+	t.Helper()
+	seg := newTestSegment(criteria)
+	require.NoError(t, repo.CreateSegment(context.Background(), seg))
+	t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.segments WHERE id = $1`, seg.ID) })
+	return seg
+}
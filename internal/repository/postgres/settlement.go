@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"kyd/internal/domain"
 	"kyd/pkg/errors"
@@ -17,7 +18,8 @@ import (
 )
 
 type SettlementRepository struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	outbox bool
 }
 
 func NewSettlementRepository(db *sqlx.DB) *SettlementRepository {
@@ -30,9 +32,9 @@ func (r *SettlementRepository) Create(ctx context.Context, settlement *domain.Se
 			id, batch_reference, network, transaction_hash, source_account,
 			destination_account, total_amount, currency, fee_amount, fee_currency,
 			status, submission_count, last_submitted_at, confirmed_at, completed_at,
-			reconciliation_id, metadata, created_at, updated_at
+			reconciliation_id, metadata, created_at, updated_at, network_fee
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20
 		)
 	`
 
@@ -42,7 +44,7 @@ func (r *SettlementRepository) Create(ctx context.Context, settlement *domain.Se
 		settlement.TotalAmount, settlement.Currency, settlement.FeeAmount, settlement.FeeCurrency,
 		settlement.Status, settlement.SubmissionCount, settlement.LastSubmittedAt,
 		settlement.ConfirmedAt, settlement.CompletedAt, settlement.ReconciliationID,
-		settlement.Metadata, settlement.CreatedAt, settlement.UpdatedAt,
+		settlement.Metadata, settlement.CreatedAt, settlement.UpdatedAt, settlement.NetworkFee,
 	)
 
 	return errors.Wrap(err, "failed to create settlement")
@@ -53,20 +55,64 @@ func (r *SettlementRepository) Update(ctx context.Context, settlement *domain.Se
 		UPDATE customer_schema.settlements SET
 			transaction_hash = $1, status = $2, submission_count = $3,
 			last_submitted_at = $4, confirmed_at = $5, completed_at = $6,
-			reconciliation_id = $7, metadata = $8, updated_at = $9
-		WHERE id = $10
+			reconciliation_id = $7, metadata = $8, updated_at = $9,
+			network_fee = $10, network = $11
+		WHERE id = $12
 	`
-
-	_, err := r.db.ExecContext(ctx, query,
+	args := []interface{}{
 		settlement.TransactionHash, settlement.Status, settlement.SubmissionCount,
 		settlement.LastSubmittedAt, settlement.ConfirmedAt, settlement.CompletedAt,
 		settlement.ReconciliationID, settlement.Metadata, settlement.UpdatedAt,
-		settlement.ID,
-	)
+		settlement.NetworkFee, settlement.Network, settlement.ID,
+	}
 
+	if r.outbox && settlement.Status == domain.SettlementStatusConfirmed {
+		return errors.Wrap(r.confirm(ctx, settlement, query, args), "failed to update settlement")
+	}
+
+	_, err := r.db.ExecContext(ctx, query, args...)
 	return errors.Wrap(err, "failed to update settlement")
 }
 
+// WithOutbox writes settlement.confirmed to the outbox, in the same
+// database transaction, when a settlement is stored as confirmed.
+func (r *SettlementRepository) WithOutbox() *SettlementRepository {
+	r.outbox = true
+	return r
+}
+
+// confirm runs the update storing settlement as confirmed together with
+// its settlement.confirmed event, unless it was confirmed already.
+func (r *SettlementRepository) confirm(ctx context.Context, settlement *domain.Settlement, query string, args []interface{}) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous domain.SettlementStatus
+	err = tx.GetContext(ctx, &previous, `SELECT status FROM customer_schema.settlements WHERE id = $1 FOR UPDATE`, settlement.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	if previous != domain.SettlementStatusConfirmed {
+		event, err := domain.SettlementConfirmed(settlement)
+		if err != nil {
+			return err
+		}
+		if err := writeOutbox(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (r *SettlementRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Settlement, error) {
 	var settlement domain.Settlement
 	query := `SELECT * FROM customer_schema.settlements WHERE id = $1`
@@ -89,7 +135,7 @@ func (r *SettlementRepository) FindAll(ctx context.Context, limit, offset int) (
 			id, batch_reference, network, transaction_hash, source_account,
 			destination_account, total_amount, currency, fee_amount, fee_currency,
 			status, submission_count, last_submitted_at, confirmed_at, completed_at,
-			reconciliation_id, metadata, created_at, updated_at
+			reconciliation_id, network_fee, metadata, created_at, updated_at
 		FROM customer_schema.settlements
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -118,7 +164,7 @@ func (r *SettlementRepository) FindAllWithFilters(ctx context.Context, limit, of
 			id, batch_reference, network, transaction_hash, source_account,
 			destination_account, total_amount, currency, fee_amount, fee_currency,
 			status, submission_count, last_submitted_at, confirmed_at, completed_at,
-			reconciliation_id, metadata, created_at, updated_at
+			reconciliation_id, network_fee, metadata, created_at, updated_at
 		FROM customer_schema.settlements
 	`
 
@@ -194,7 +240,7 @@ func (r *SettlementRepository) FindSubmitted(ctx context.Context) ([]*domain.Set
 			id, batch_reference, network, transaction_hash, source_account,
 			destination_account, total_amount, currency, fee_amount, fee_currency,
 			status, submission_count, last_submitted_at, confirmed_at, completed_at,
-			metadata, created_at, updated_at
+			network_fee, metadata, created_at, updated_at
 		FROM customer_schema.settlements
 		WHERE status = $1
 	`
@@ -204,3 +250,26 @@ func (r *SettlementRepository) FindSubmitted(ctx context.Context) ([]*domain.Set
 	}
 	return settlements, nil
 }
+
+// SummarizeNetworkFees aggregates fees paid on submitted settlements created in [from, to).
+func (r *SettlementRepository) SummarizeNetworkFees(ctx context.Context, from, to time.Time) ([]*domain.NetworkFeeSummary, error) {
+	var items []*domain.NetworkFeeSummary
+	query := `
+		SELECT
+			network,
+			currency,
+			COUNT(*) AS settlement_count,
+			COALESCE(SUM(network_fee), 0) AS total_fees,
+			COALESCE(AVG(network_fee), 0) AS average_fee,
+			COALESCE(MAX(network_fee), 0) AS max_fee,
+			COALESCE(SUM(total_amount), 0) AS total_volume
+		FROM customer_schema.settlements
+		WHERE submission_count > 0 AND created_at >= $1 AND created_at < $2
+		GROUP BY network, currency
+		ORDER BY network, currency
+	`
+	if err := r.db.SelectContext(ctx, &items, query, from, to); err != nil {
+		return nil, errors.Wrap(err, "failed to summarize network fees")
+	}
+	return items, nil
+}
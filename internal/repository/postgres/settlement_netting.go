@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/settlement"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// SettlementNettingRepository reads payments for net corridors and stores
+// the audit of each netted window.
+type SettlementNettingRepository struct {
+	db *sqlx.DB
+}
+
+func NewSettlementNettingRepository(db *sqlx.DB) *SettlementNettingRepository {
+	return &SettlementNettingRepository{db: db}
+}
+
+const settlementNettingColumns = `
+	id, settlement_id, corridor, window_start, window_end, quote_currency,
+	outbound, inbound, net_position, settlement_amount, settlement_currency,
+	transaction_count, transactions, created_at`
+
+func (r *SettlementNettingRepository) FindPendingSettlementExcept(ctx context.Context, pairs []string, limit int) ([]*domain.Transaction, error) {
+	txs := []*domain.Transaction{}
+	query := `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE status = 'pending_settlement' AND settlement_id IS NULL
+			AND currency || '-' || converted_currency <> ALL($1)
+		ORDER BY completed_at ASC, id ASC
+		LIMIT $2
+	`
+	if err := r.db.SelectContext(ctx, &txs, query, pq.Array(pairs), limit); err != nil {
+		return nil, errors.Wrap(err, "failed to find pending settlements")
+	}
+	return txs, nil
+}
+
+func (r *SettlementNettingRepository) FindNettable(ctx context.Context, pairs []string, before time.Time, limit int) ([]*domain.Transaction, error) {
+	txs := []*domain.Transaction{}
+	query := `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE status = 'pending_settlement' AND settlement_id IS NULL
+			AND currency || '-' || converted_currency = ANY($1)
+			AND COALESCE(completed_at, created_at) < $2
+		ORDER BY COALESCE(completed_at, created_at) ASC, id ASC
+		LIMIT $3
+	`
+	if err := r.db.SelectContext(ctx, &txs, query, pq.Array(pairs), before, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to find nettable payments")
+	}
+	return txs, nil
+}
+
+func (r *SettlementNettingRepository) CreateNetting(ctx context.Context, n *domain.SettlementNetting) error {
+	query := `
+		INSERT INTO customer_schema.settlement_nettings (` + settlementNettingColumns + `)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		n.ID, n.SettlementID, n.Corridor, n.WindowStart, n.WindowEnd, n.QuoteCurrency,
+		n.Outbound, n.Inbound, n.NetPosition, n.SettlementAmount, n.SettlementCurrency,
+		n.TransactionCount, n.Transactions, n.CreatedAt,
+	)
+	return errors.Wrap(err, "failed to create settlement netting")
+}
+
+func (r *SettlementNettingRepository) FindNettingBySettlementID(ctx context.Context, settlementID uuid.UUID) (*domain.SettlementNetting, error) {
+	var n domain.SettlementNetting
+	err := r.db.GetContext(ctx, &n, `SELECT `+settlementNettingColumns+` FROM customer_schema.settlement_nettings WHERE settlement_id = $1`, settlementID)
+	if err == sql.ErrNoRows {
+		return nil, settlement.ErrNettingNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get settlement netting")
+	}
+	return &n, nil
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/settlement"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SettlementRouteRepository struct {
+	db *sqlx.DB
+}
+
+func NewSettlementRouteRepository(db *sqlx.DB) *SettlementRouteRepository {
+	return &SettlementRouteRepository{db: db}
+}
+
+func (r *SettlementRouteRepository) CreateRoute(ctx context.Context, rt *domain.SettlementRoute) error {
+	query := `
+		INSERT INTO admin_schema.settlement_routes (
+			id, corridor, min_amount, max_amount, network, effective_from,
+			effective_to, notes, created_by, created_at, updated_at
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		rt.ID, rt.Corridor, rt.MinAmount, rt.MaxAmount, rt.Network, rt.EffectiveFrom,
+		rt.EffectiveTo, rt.Notes, rt.CreatedBy, rt.CreatedAt, rt.UpdatedAt,
+	)
+	return errors.Wrap(err, "failed to create settlement route")
+}
+
+func (r *SettlementRouteRepository) UpdateRoute(ctx context.Context, rt *domain.SettlementRoute) error {
+	query := `
+		UPDATE admin_schema.settlement_routes SET
+			corridor = $1,
+			min_amount = $2,
+			max_amount = $3,
+			network = $4,
+			effective_from = $5,
+			effective_to = $6,
+			notes = $7,
+			updated_at = $8
+		WHERE id = $9
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		rt.Corridor, rt.MinAmount, rt.MaxAmount, rt.Network, rt.EffectiveFrom,
+		rt.EffectiveTo, rt.Notes, rt.UpdatedAt, rt.ID,
+	)
+	return errors.Wrap(err, "failed to update settlement route")
+}
+
+func (r *SettlementRouteRepository) DeleteRoute(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.settlement_routes WHERE id = $1`, id)
+	return errors.Wrap(err, "failed to delete settlement route")
+}
+
+func (r *SettlementRouteRepository) GetRoute(ctx context.Context, id uuid.UUID) (*domain.SettlementRoute, error) {
+	var rt domain.SettlementRoute
+	err := r.db.GetContext(ctx, &rt, `SELECT * FROM admin_schema.settlement_routes WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, settlement.ErrRouteNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get settlement route")
+	}
+	return &rt, nil
+}
+
+// ListRoutes returns all routes, or those for one corridor (plus wildcard routes)
+// when corridor is non-empty.
+func (r *SettlementRouteRepository) ListRoutes(ctx context.Context, corridor string) ([]*domain.SettlementRoute, error) {
+	var items []*domain.SettlementRoute
+	query := `
+		SELECT *
+		FROM admin_schema.settlement_routes
+		WHERE ($1 = '' OR corridor = $1 OR corridor = '*')
+		ORDER BY corridor, min_amount, effective_from
+	`
+	if err := r.db.SelectContext(ctx, &items, query, corridor); err != nil {
+		return nil, errors.Wrap(err, "failed to list settlement routes")
+	}
+	return items, nil
+}
+
+// ListRouteSamples returns transactions created in [from, to) with the rail
+// they actually settled on, if any.
+func (r *SettlementRouteRepository) ListRouteSamples(ctx context.Context, from, to time.Time) ([]*domain.RouteSample, error) {
+	var items []*domain.RouteSample
+	query := `
+		SELECT t.currency, t.converted_currency, t.converted_amount, s.network, t.created_at
+		FROM customer_schema.transactions t
+		LEFT JOIN customer_schema.settlements s ON s.id = t.settlement_id
+		WHERE t.created_at >= $1 AND t.created_at < $2
+		ORDER BY t.created_at
+	`
+	if err := r.db.SelectContext(ctx, &items, query, from, to); err != nil {
+		return nil, errors.Wrap(err, "failed to list route samples")
+	}
+	return items, nil
+}
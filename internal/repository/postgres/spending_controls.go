@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type SpendingControlRepository struct {
+	db *sqlx.DB
+}
+
+func NewSpendingControlRepository(db *sqlx.DB) *SpendingControlRepository {
+	return &SpendingControlRepository{db: db}
+}
+
+const spendingControlColumns = `user_id, max_per_transaction, daily_cap, block_cross_border, blocked_channels, frozen, updated_at`
+
+func (r *SpendingControlRepository) Get(ctx context.Context, userID uuid.UUID) (*domain.SpendingControls, error) {
+	var c domain.SpendingControls
+	query := `SELECT ` + spendingControlColumns + ` FROM customer_schema.spending_controls WHERE user_id = $1`
+	err := r.db.GetContext(ctx, &c, query, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get spending controls")
+	}
+	return &c, nil
+}
+
+func (r *SpendingControlRepository) Upsert(ctx context.Context, c *domain.SpendingControls) error {
+	query := `
+		INSERT INTO customer_schema.spending_controls (` + spendingControlColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			max_per_transaction = EXCLUDED.max_per_transaction,
+			daily_cap = EXCLUDED.daily_cap,
+			block_cross_border = EXCLUDED.block_cross_border,
+			blocked_channels = EXCLUDED.blocked_channels,
+			frozen = EXCLUDED.frozen,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, c.UserID, c.MaxPerTransaction, c.DailyCap,
+		c.BlockCrossBorder, c.BlockedChannels, c.Frozen, c.UpdatedAt)
+	return errors.Wrap(err, "failed to save spending controls")
+}
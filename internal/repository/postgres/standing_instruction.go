@@ -0,0 +1,318 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/standing"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const standingInstructionColumns = `
+	id, user_id, wallet_id, currency, kind, target_currency, pocket_id,
+	threshold, active, active_since, created_at, updated_at
+`
+
+const savingsPocketColumns = `id, user_id, wallet_id, currency, name, balance, created_at, updated_at`
+
+// StandingInstructionRepository stores standing instructions on incoming
+// funds, what they did, and the savings pockets they sweep into.
+type StandingInstructionRepository struct {
+	db *sqlx.DB
+}
+
+func NewStandingInstructionRepository(db *sqlx.DB) *StandingInstructionRepository {
+	return &StandingInstructionRepository{db: db}
+}
+
+func (r *StandingInstructionRepository) CreateInstruction(ctx context.Context, in *domain.StandingInstruction) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.standing_instructions (`+standingInstructionColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+	`, in.ID, in.UserID, in.WalletID, in.Currency, in.Kind, in.TargetCurrency, in.PocketID,
+		in.Threshold, in.Active, in.ActiveSince, in.CreatedAt, in.UpdatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return standing.ErrInstructionExists
+	}
+	return errors.Wrap(err, "failed to create standing instruction")
+}
+
+func (r *StandingInstructionRepository) GetInstruction(ctx context.Context, id uuid.UUID) (*domain.StandingInstruction, error) {
+	var in domain.StandingInstruction
+	err := r.db.GetContext(ctx, &in, `
+		SELECT `+standingInstructionColumns+`
+		FROM customer_schema.standing_instructions
+		WHERE id = $1
+	`, id)
+	if err == sql.ErrNoRows {
+		return nil, standing.ErrInstructionNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get standing instruction")
+	}
+	return &in, nil
+}
+
+func (r *StandingInstructionRepository) ListInstructions(ctx context.Context, userID uuid.UUID) ([]domain.StandingInstruction, error) {
+	items := []domain.StandingInstruction{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT `+standingInstructionColumns+`
+		FROM customer_schema.standing_instructions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list standing instructions")
+	}
+	return items, nil
+}
+
+func (r *StandingInstructionRepository) UpdateInstruction(ctx context.Context, in *domain.StandingInstruction) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.standing_instructions
+		SET threshold = $2, active = $3, active_since = $4, updated_at = $5
+		WHERE id = $1
+	`, in.ID, in.Threshold, in.Active, in.ActiveSince, in.UpdatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return standing.ErrInstructionExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update standing instruction")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return standing.ErrInstructionNotFound
+	}
+	return nil
+}
+
+func (r *StandingInstructionRepository) DeleteInstruction(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM customer_schema.standing_instructions WHERE id = $1`, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete standing instruction")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return standing.ErrInstructionNotFound
+	}
+	return nil
+}
+
+func (r *StandingInstructionRepository) ListExecutions(ctx context.Context, instructionID uuid.UUID, limit, offset int) ([]domain.StandingInstructionExecution, int, error) {
+	var total int
+	if err := r.db.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM customer_schema.standing_instruction_executions WHERE instruction_id = $1
+	`, instructionID); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count standing instruction executions")
+	}
+	items := []domain.StandingInstructionExecution{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT id, instruction_id, trigger_transaction_id, status, amount, transaction_id, reason, claimed_at, created_at
+		FROM customer_schema.standing_instruction_executions
+		WHERE instruction_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, instructionID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list standing instruction executions")
+	}
+	return items, total, nil
+}
+
+func (r *StandingInstructionRepository) PendingCredits(ctx context.Context, stale time.Time, limit int) ([]domain.IncomingCredit, error) {
+	items := []domain.IncomingCredit{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT si.id AS instruction_id, t.id AS transaction_id, t.converted_amount AS amount
+		FROM customer_schema.standing_instructions si
+		JOIN customer_schema.transactions t ON t.receiver_wallet_id = si.wallet_id
+		LEFT JOIN customer_schema.standing_instruction_executions e
+			ON e.instruction_id = si.id AND e.trigger_transaction_id = t.id
+		WHERE si.active
+			AND t.status = $1
+			AND t.transaction_type IN ($2, $3)
+			AND t.completed_at >= si.active_since
+			AND COALESCE(t.category, '') <> $4
+			AND (e.id IS NULL OR (e.status = $5 AND e.claimed_at < $6))
+		ORDER BY t.completed_at
+		LIMIT $7
+	`, domain.TransactionStatusCompleted, domain.TransactionTypePayment, domain.TransactionTypeDeposit,
+		standing.Category, domain.StandingExecutionPending, stale, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find incoming credits")
+	}
+	return items, nil
+}
+
+func (r *StandingInstructionRepository) ClaimCredit(ctx context.Context, e *domain.StandingInstructionExecution, stale time.Time) (bool, error) {
+	var id uuid.UUID
+	err := r.db.GetContext(ctx, &id, `
+		INSERT INTO customer_schema.standing_instruction_executions (
+			id, instruction_id, trigger_transaction_id, status, amount, reason, claimed_at, created_at
+		) VALUES ($1,$2,$3,$4,$5,'',$6,$7)
+		ON CONFLICT (instruction_id, trigger_transaction_id) DO UPDATE
+			SET claimed_at = EXCLUDED.claimed_at
+			WHERE standing_instruction_executions.status = $4
+				AND standing_instruction_executions.claimed_at < $8
+		RETURNING id
+	`, e.ID, e.InstructionID, e.TriggerTransactionID, domain.StandingExecutionPending, e.Amount, e.ClaimedAt, e.CreatedAt, stale)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to claim incoming credit")
+	}
+	e.ID = id
+	return true, nil
+}
+
+func (r *StandingInstructionRepository) FinishExecution(ctx context.Context, e *domain.StandingInstructionExecution) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customer_schema.standing_instruction_executions
+		SET status = $2, amount = $3, transaction_id = $4, reason = $5
+		WHERE id = $1
+	`, e.ID, e.Status, e.Amount, e.TransactionID, e.Reason)
+	return errors.Wrap(err, "failed to record standing instruction execution")
+}
+
+func (r *StandingInstructionRepository) CreatePocket(ctx context.Context, p *domain.SavingsPocket) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.savings_pockets (`+savingsPocketColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	`, p.ID, p.UserID, p.WalletID, p.Currency, p.Name, p.Balance, p.CreatedAt, p.UpdatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return standing.ErrPocketExists
+	}
+	return errors.Wrap(err, "failed to create savings pocket")
+}
+
+func (r *StandingInstructionRepository) GetPocket(ctx context.Context, id uuid.UUID) (*domain.SavingsPocket, error) {
+	var p domain.SavingsPocket
+	err := r.db.GetContext(ctx, &p, `SELECT `+savingsPocketColumns+` FROM customer_schema.savings_pockets WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, standing.ErrPocketNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get savings pocket")
+	}
+	return &p, nil
+}
+
+func (r *StandingInstructionRepository) ListPockets(ctx context.Context, userID uuid.UUID) ([]domain.SavingsPocket, error) {
+	items := []domain.SavingsPocket{}
+	err := r.db.SelectContext(ctx, &items, `
+		SELECT `+savingsPocketColumns+`
+		FROM customer_schema.savings_pockets
+		WHERE user_id = $1
+		ORDER BY currency, name
+	`, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list savings pockets")
+	}
+	return items, nil
+}
+
+// SweepToPocket reserves the wallet's available balance above keep and adds
+// it to the pocket in one transaction, the wallet row locked so that a
+// payment cannot spend the same money meanwhile.
+func (r *StandingInstructionRepository) SweepToPocket(ctx context.Context, pocketID uuid.UUID, keep decimal.Decimal) (*domain.SavingsPocket, decimal.Decimal, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, decimal.Zero, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	var moved decimal.Decimal
+	err = tx.GetContext(ctx, &moved, `
+		WITH w AS (
+			SELECT w.id, w.available_balance - $2::numeric AS moved
+			FROM customer_schema.wallets w
+			JOIN customer_schema.savings_pockets p ON p.wallet_id = w.id
+			WHERE p.id = $1
+			FOR UPDATE OF w
+		)
+		UPDATE customer_schema.wallets SET
+			available_balance = available_balance - w.moved,
+			reserved_balance = reserved_balance + w.moved,
+			updated_at = NOW()
+		FROM w
+		WHERE wallets.id = w.id AND w.moved > 0
+		RETURNING w.moved
+	`, pocketID, keep)
+	if err == sql.ErrNoRows {
+		p, err := getPocket(ctx, tx, pocketID)
+		return p, decimal.Zero, err
+	}
+	if err != nil {
+		return nil, decimal.Zero, errors.Wrap(err, "failed to sweep wallet")
+	}
+	p, err := addToPocket(ctx, tx, pocketID, moved)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+	return p, moved, errors.Wrap(tx.Commit(), "transaction commit failed")
+}
+
+func (r *StandingInstructionRepository) WithdrawFromPocket(ctx context.Context, pocketID uuid.UUID, amount decimal.Decimal) (*domain.SavingsPocket, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction failed")
+	}
+	defer tx.Rollback()
+
+	p, err := addToPocket(ctx, tx, pocketID, amount.Neg())
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE customer_schema.wallets SET
+			available_balance = available_balance + $1,
+			reserved_balance = reserved_balance - $1,
+			updated_at = NOW()
+		WHERE id = $2 AND reserved_balance >= $1
+	`, amount, p.WalletID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to release pocket funds")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, errors.ErrInsufficientBalance
+	}
+	return p, errors.Wrap(tx.Commit(), "transaction commit failed")
+}
+
+// addToPocket changes the pocket's balance by amount, refusing to take it
+// below zero.
+func addToPocket(ctx context.Context, tx *sqlx.Tx, pocketID uuid.UUID, amount decimal.Decimal) (*domain.SavingsPocket, error) {
+	var p domain.SavingsPocket
+	err := tx.GetContext(ctx, &p, `
+		UPDATE customer_schema.savings_pockets
+		SET balance = balance + $2, updated_at = NOW()
+		WHERE id = $1 AND balance + $2 >= 0
+		RETURNING `+savingsPocketColumns, pocketID, amount)
+	if err == sql.ErrNoRows {
+		if _, err := getPocket(ctx, tx, pocketID); err != nil {
+			return nil, err
+		}
+		return nil, standing.ErrPocketBalance
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update savings pocket")
+	}
+	return &p, nil
+}
+
+func getPocket(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (*domain.SavingsPocket, error) {
+	var p domain.SavingsPocket
+	err := tx.GetContext(ctx, &p, `SELECT `+savingsPocketColumns+` FROM customer_schema.savings_pockets WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, standing.ErrPocketNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get savings pocket")
+	}
+	return &p, nil
+}
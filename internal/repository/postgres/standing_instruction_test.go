@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/standing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandingInstructionRepository_ClaimsEachCreditOnce(t *testing.T) {
+	db := testDB(t)
+	repo := NewStandingInstructionRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := testUser(t, db, now)
+	wallet := testWallet(t, db, user, "MWK", domain.WalletStatusActive)
+	before := testCredit(t, db, user, wallet, 100, domain.TransactionStatusCompleted, "", now.Add(-time.Hour))
+	credit := testCredit(t, db, user, wallet, 200, domain.TransactionStatusCompleted, "", now.Add(time.Minute))
+	testCredit(t, db, user, wallet, 300, domain.TransactionStatusFailed, "", now.Add(time.Minute))
+	testCredit(t, db, user, wallet, 400, domain.TransactionStatusCompleted, standing.Category, now.Add(time.Minute))
+
+	target := domain.CNY
+	in := &domain.StandingInstruction{
+		ID: uuid.New(), UserID: user, WalletID: wallet, Currency: "MWK", Kind: domain.StandingInstructionConvert,
+		TargetCurrency: &target, Active: true, ActiveSince: now, CreatedAt: now, UpdatedAt: now,
+	}
+	require.NoError(t, repo.CreateInstruction(ctx, in))
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.standing_instructions WHERE user_id = $1`, user) })
+	second := *in
+	second.ID = uuid.New()
+	assert.ErrorIs(t, repo.CreateInstruction(ctx, &second), standing.ErrInstructionExists, "one active instruction per wallet")
+
+	pending := func(stale time.Time) []uuid.UUID {
+		credits, err := repo.PendingCredits(ctx, stale, 100000)
+		require.NoError(t, err)
+		var ids []uuid.UUID
+		for _, c := range credits {
+			if c.InstructionID == in.ID {
+				ids = append(ids, c.TransactionID)
+			}
+		}
+		return ids
+	}
+	// Not what came before the instruction, failed or was made by one.
+	require.Equal(t, []uuid.UUID{credit}, pending(now))
+	assert.NotContains(t, pending(now), before)
+
+	claim := func(stale time.Time) (*domain.StandingInstructionExecution, bool) {
+		e := &domain.StandingInstructionExecution{
+			ID: uuid.New(), InstructionID: in.ID, TriggerTransactionID: credit,
+			Status: domain.StandingExecutionPending, Amount: decimal.Zero, ClaimedAt: now, CreatedAt: now,
+		}
+		claimed, err := repo.ClaimCredit(ctx, e, stale)
+		require.NoError(t, err)
+		return e, claimed
+	}
+	first, ok := claim(now.Add(-time.Minute))
+	require.True(t, ok)
+	_, ok = claim(now.Add(-time.Minute))
+	assert.False(t, ok, "the claim is held")
+	assert.Empty(t, pending(now.Add(-time.Minute)))
+
+	// Stale: another run takes it over under the same ID.
+	require.Equal(t, []uuid.UUID{credit}, pending(now.Add(time.Minute)))
+	takeover, ok := claim(now.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, first.ID, takeover.ID)
+
+	takeover.Status, takeover.Amount = domain.StandingExecutionExecuted, decimal.NewFromInt(200)
+	require.NoError(t, repo.FinishExecution(ctx, takeover))
+	assert.Empty(t, pending(now.Add(time.Hour)), "finished credits are never taken over")
+	_, ok = claim(now.Add(time.Hour))
+	assert.False(t, ok)
+}
+
+func TestStandingInstructionRepository_Pockets(t *testing.T) {
+	db := testDB(t)
+	repo := NewStandingInstructionRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	user := testUser(t, db, now)
+	wallet := testWallet(t, db, user, "MWK", domain.WalletStatusActive)
+	_, err := db.Exec(`UPDATE customer_schema.wallets SET available_balance = 1000, ledger_balance = 1000 WHERE id = $1`, wallet)
+	require.NoError(t, err)
+	p := &domain.SavingsPocket{ID: uuid.New(), UserID: user, WalletID: wallet, Currency: "MWK", Name: "Rainy day", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, repo.CreatePocket(ctx, p))
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.savings_pockets WHERE user_id = $1`, user) })
+	dup := *p
+	dup.ID, dup.Name = uuid.New(), "RAINY DAY"
+	assert.ErrorIs(t, repo.CreatePocket(ctx, &dup), standing.ErrPocketExists)
+
+	balances := func() (string, string) {
+		var available, reserved decimal.Decimal
+		require.NoError(t, db.QueryRow(`SELECT available_balance, reserved_balance FROM customer_schema.wallets WHERE id = $1`, wallet).Scan(&available, &reserved))
+		return available.String(), reserved.String()
+	}
+
+	got, moved, err := repo.SweepToPocket(ctx, p.ID, decimal.NewFromInt(300))
+	require.NoError(t, err)
+	assert.Equal(t, "700", moved.String())
+	assert.Equal(t, "700", got.Balance.String())
+	available, reserved := balances()
+	assert.Equal(t, "300", available)
+	assert.Equal(t, "700", reserved)
+
+	_, moved, err = repo.SweepToPocket(ctx, p.ID, decimal.NewFromInt(300))
+	require.NoError(t, err)
+	assert.True(t, moved.IsZero(), "nothing above the threshold")
+
+	_, err = repo.WithdrawFromPocket(ctx, p.ID, decimal.NewFromInt(701))
+	assert.ErrorIs(t, err, standing.ErrPocketBalance)
+	got, err = repo.WithdrawFromPocket(ctx, p.ID, decimal.NewFromInt(200))
+	require.NoError(t, err)
+	assert.Equal(t, "500", got.Balance.String())
+	available, reserved = balances()
+	assert.Equal(t, "500", available)
+	assert.Equal(t, "500", reserved)
+
+	_, _, err = repo.SweepToPocket(ctx, uuid.New(), decimal.Zero)
+	assert.ErrorIs(t, err, standing.ErrPocketNotFound)
+}
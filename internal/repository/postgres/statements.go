@@ -0,0 +1,272 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/statement"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+type StatementRepository struct {
+	db *sqlx.DB
+}
+
+func NewStatementRepository(db *sqlx.DB) *StatementRepository {
+	return &StatementRepository{db: db}
+}
+
+const statementScheduleColumns = `id, owner_id, wallet_id, format, channel, last_period, created_by, created_at`
+
+// statementDeliveryFields are the columns of a delivery but its file.
+const statementDeliveryFields = `id, schedule_id, owner_id, wallet_id, period, format, channel, regenerated_from,
+	status, attempts, next_attempt_at, last_error, file_name, file_size, sha256, entry_count,
+	requested_by, sent_at, delivered_at, created_at, updated_at`
+
+const statementDeliveryColumns = statementDeliveryFields + `, content`
+
+const insertStatementDelivery = `
+	INSERT INTO admin_schema.statement_deliveries (` + statementDeliveryColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)`
+
+func statementDeliveryArgs(d *domain.StatementDelivery) []interface{} {
+	return []interface{}{
+		d.ID, d.ScheduleID, d.OwnerID, d.WalletID, d.Period, d.Format, d.Channel, d.RegeneratedFrom,
+		d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.FileName, d.FileSize, d.SHA256, d.EntryCount,
+		d.RequestedBy, d.SentAt, d.DeliveredAt, d.CreatedAt, d.UpdatedAt, d.Content,
+	}
+}
+
+func (r *StatementRepository) CreateSchedule(ctx context.Context, sch *domain.StatementSchedule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.statement_schedules (`+statementScheduleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, sch.ID, sch.OwnerID, sch.WalletID, sch.Format, sch.Channel, sch.LastPeriod, sch.CreatedBy, sch.CreatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+		return statement.ErrScheduleExists
+	}
+	return errors.Wrap(err, "failed to create statement schedule")
+}
+
+func (r *StatementRepository) GetSchedule(ctx context.Context, id uuid.UUID) (*domain.StatementSchedule, error) {
+	var sch domain.StatementSchedule
+	err := r.db.GetContext(ctx, &sch, `SELECT `+statementScheduleColumns+` FROM admin_schema.statement_schedules WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get statement schedule")
+	}
+	return &sch, nil
+}
+
+func (r *StatementRepository) ListSchedules(ctx context.Context, owner *uuid.UUID) ([]domain.StatementSchedule, error) {
+	schedules := []domain.StatementSchedule{}
+	query := `SELECT ` + statementScheduleColumns + ` FROM admin_schema.statement_schedules`
+	var args []interface{}
+	if owner != nil {
+		query += ` WHERE owner_id = $1`
+		args = append(args, *owner)
+	}
+	query += ` ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &schedules, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to list statement schedules")
+	}
+	return schedules, nil
+}
+
+func (r *StatementRepository) DeleteSchedule(ctx context.Context, id uuid.UUID, owner *uuid.UUID) (bool, error) {
+	query := `DELETE FROM admin_schema.statement_schedules WHERE id = $1`
+	args := []interface{}{id}
+	if owner != nil {
+		query += ` AND owner_id = $2`
+		args = append(args, *owner)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete statement schedule")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *StatementRepository) ListDueSchedules(ctx context.Context, period time.Time, limit int) ([]domain.StatementSchedule, error) {
+	schedules := []domain.StatementSchedule{}
+	err := r.db.SelectContext(ctx, &schedules, `
+		SELECT `+statementScheduleColumns+` FROM admin_schema.statement_schedules
+		WHERE last_period IS NULL OR last_period < $1::date
+		ORDER BY created_at
+		LIMIT $2
+	`, period, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list due statement schedules")
+	}
+	return schedules, nil
+}
+
+func (r *StatementRepository) QueueScheduledStatement(ctx context.Context, scheduleID uuid.UUID, period time.Time, d *domain.StatementDelivery) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_schema.statement_schedules SET last_period = $2::date
+		WHERE id = $1 AND (last_period IS NULL OR last_period < $2::date)
+	`, scheduleID, period)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to claim statement schedule")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, insertStatementDelivery, statementDeliveryArgs(d)...); err != nil {
+		return false, errors.Wrap(err, "failed to queue scheduled statement")
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "failed to commit scheduled statement")
+	}
+	return true, nil
+}
+
+func (r *StatementRepository) CreateDelivery(ctx context.Context, d *domain.StatementDelivery) error {
+	_, err := r.db.ExecContext(ctx, insertStatementDelivery, statementDeliveryArgs(d)...)
+	return errors.Wrap(err, "failed to create statement delivery")
+}
+
+func (r *StatementRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*domain.StatementDelivery, error) {
+	var d domain.StatementDelivery
+	err := r.db.GetContext(ctx, &d, `SELECT `+statementDeliveryColumns+` FROM admin_schema.statement_deliveries WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get statement delivery")
+	}
+	return &d, nil
+}
+
+func (r *StatementRepository) ListDeliveries(ctx context.Context, filter statement.DeliveryFilter) ([]domain.StatementDelivery, int, error) {
+	where := []string{"TRUE"}
+	var args []interface{}
+	add := func(clause string, value interface{}) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+	if filter.OwnerID != nil {
+		add("owner_id = $%d", *filter.OwnerID)
+	}
+	if filter.ScheduleID != nil {
+		add("schedule_id = $%d", *filter.ScheduleID)
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if filter.Period != nil {
+		add("period = $%d::date", *filter.Period)
+	}
+	conditions := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.statement_deliveries WHERE `+conditions, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count statement deliveries")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	deliveries := []domain.StatementDelivery{}
+	query := `SELECT ` + statementDeliveryFields + ` FROM admin_schema.statement_deliveries WHERE ` + conditions +
+		fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	if err := r.db.SelectContext(ctx, &deliveries, query, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list statement deliveries")
+	}
+	return deliveries, total, nil
+}
+
+func (r *StatementRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.StatementDelivery, error) {
+	deliveries := []domain.StatementDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, `
+		UPDATE admin_schema.statement_deliveries
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM admin_schema.statement_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+statementDeliveryColumns,
+		now, now.Add(lease), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim statement deliveries")
+	}
+	return deliveries, nil
+}
+
+func (r *StatementRepository) ListSent(ctx context.Context, limit int) ([]domain.StatementDelivery, error) {
+	deliveries := []domain.StatementDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, `
+		SELECT `+statementDeliveryFields+` FROM admin_schema.statement_deliveries
+		WHERE status = 'sent'
+		ORDER BY updated_at
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sent statements")
+	}
+	return deliveries, nil
+}
+
+func (r *StatementRepository) SaveFile(ctx context.Context, d *domain.StatementDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.statement_deliveries
+		SET file_name = $2, file_size = $3, sha256 = $4, entry_count = $5, content = $6
+		WHERE id = $1
+	`, d.ID, d.FileName, d.FileSize, d.SHA256, d.EntryCount, d.Content)
+	return errors.Wrap(err, "failed to save statement file")
+}
+
+func (r *StatementRepository) UpdateDelivery(ctx context.Context, d *domain.StatementDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.statement_deliveries SET
+			status = $2, attempts = $3, next_attempt_at = $4, last_error = $5,
+			sent_at = $6, delivered_at = $7, updated_at = $8
+		WHERE id = $1
+	`, d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.SentAt, d.DeliveredAt, d.UpdatedAt)
+	return errors.Wrap(err, "failed to update statement delivery")
+}
+
+func (r *StatementRepository) StatementEntries(ctx context.Context, walletID uuid.UUID, from, to time.Time) (decimal.Decimal, []domain.StatementEntry, error) {
+	var opening decimal.Decimal
+	err := r.db.GetContext(ctx, &opening, `
+		SELECT COALESCE((
+			SELECT balance_after FROM customer_schema.ledger_entries
+			WHERE wallet_id = $1 AND created_at < $2
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		), 0)
+	`, walletID, from)
+	if err != nil {
+		return decimal.Zero, nil, errors.Wrap(err, "failed to get opening balance")
+	}
+	entries := []domain.StatementEntry{}
+	err = r.db.SelectContext(ctx, &entries, `
+		SELECT e.id, e.transaction_id, e.entry_type, e.amount, e.balance_after,
+			COALESCE(t.reference, '') AS reference, COALESCE(t.description, '') AS description, e.created_at
+		FROM customer_schema.ledger_entries e
+		LEFT JOIN customer_schema.transactions t ON t.id = e.transaction_id
+		WHERE e.wallet_id = $1 AND e.created_at >= $2 AND e.created_at < $3
+		ORDER BY e.created_at, e.id
+	`, walletID, from, to)
+	if err != nil {
+		return decimal.Zero, nil, errors.Wrap(err, "failed to list statement entries")
+	}
+	return opening, entries, nil
+}
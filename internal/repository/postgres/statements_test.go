@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/statement"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewStatementRepository(db)
+	// Long past, so no real delivery is ever due before these
+	day := time.Date(1990, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := day.Add(26 * time.Hour)
+	owner := testUser(t, db, day)
+	wallet := testWallet(t, db, owner, domain.MWK, domain.WalletStatusActive)
+	t.Cleanup(func() { db.Exec(`DELETE FROM admin_schema.statement_deliveries WHERE owner_id = $1`, owner) })
+
+	sch := &domain.StatementSchedule{
+		ID: uuid.New(), OwnerID: owner, WalletID: wallet, Format: domain.StatementFormatCSV,
+		Channel: domain.StatementChannelSFTP, CreatedBy: owner, CreatedAt: day,
+	}
+	require.NoError(t, repo.CreateSchedule(ctx, sch))
+	dup := *sch
+	dup.ID = uuid.New()
+	assert.ErrorIs(t, repo.CreateSchedule(ctx, &dup), statement.ErrScheduleExists)
+
+	t.Run("each day is queued once", func(t *testing.T) {
+		due, err := repo.ListDueSchedules(ctx, day, 1000)
+		require.NoError(t, err)
+		assert.Contains(t, scheduleIDs(due), sch.ID)
+
+		ok, err := repo.QueueScheduledStatement(ctx, sch.ID, day, testDelivery(sch, day, now))
+		require.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = repo.QueueScheduledStatement(ctx, sch.ID, day, testDelivery(sch, day, now))
+		require.NoError(t, err)
+		assert.False(t, ok, "already queued by another worker")
+
+		due, err = repo.ListDueSchedules(ctx, day, 1000)
+		require.NoError(t, err)
+		assert.NotContains(t, scheduleIDs(due), sch.ID)
+		_, total, err := repo.ListDeliveries(ctx, statement.DeliveryFilter{ScheduleID: &sch.ID, Period: &day, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 1, total, "nothing of the refused one is stored")
+	})
+
+	t.Run("claimed deliveries are left alone for the lease", func(t *testing.T) {
+		d := testDelivery(sch, day.AddDate(0, 0, -1), now.Add(-time.Hour))
+		require.NoError(t, repo.CreateDelivery(ctx, d))
+
+		claimed, err := repo.ClaimDue(ctx, now, 5*time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, d.ID, claimed[0].ID, "the longest due first")
+		again, err := repo.ClaimDue(ctx, now.Add(time.Minute), 5*time.Minute, 10)
+		require.NoError(t, err)
+		for _, c := range again {
+			assert.NotEqual(t, d.ID, c.ID)
+		}
+
+		d.FileName, d.Content, d.FileSize, d.EntryCount = "statement.csv", []byte("a,b\n"), 4, 1
+		require.NoError(t, repo.SaveFile(ctx, d))
+		d.Status, d.Attempts, d.SentAt, d.NextAttemptAt, d.Content = domain.StatementDeliveryDelivered, 1, &now, nil, nil
+		require.NoError(t, repo.UpdateDelivery(ctx, d))
+
+		got, err := repo.GetDelivery(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatementDeliveryDelivered, got.Status)
+		assert.Equal(t, []byte("a,b\n"), got.Content, "updates leave the file alone")
+
+		listed, _, err := repo.ListDeliveries(ctx, statement.DeliveryFilter{OwnerID: &owner, Status: domain.StatementDeliveryDelivered, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Nil(t, listed[0].Content, "listed without files")
+	})
+
+	t.Run("entries of the day", func(t *testing.T) {
+		tx := testCredit(t, db, owner, wallet, 100, domain.TransactionStatusCompleted, "", day)
+		before := testEntry(t, db, tx, wallet, 100, 100, day.Add(-time.Hour))
+		first := testEntry(t, db, tx, wallet, 50, 150, day)
+		last := testEntry(t, db, tx, wallet, 25, 175, day.Add(23*time.Hour))
+		testEntry(t, db, tx, wallet, 5, 180, day.AddDate(0, 0, 1))
+
+		opening, entries, err := repo.StatementEntries(ctx, wallet, day, day.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.True(t, opening.Equal(decimal.NewFromInt(100)), "the balance after %s", before)
+		require.Len(t, entries, 2)
+		assert.Equal(t, []uuid.UUID{first, last}, []uuid.UUID{entries[0].ID, entries[1].ID})
+		assert.Equal(t, "TEST-"+tx.String(), entries[0].Reference)
+
+		opening, entries, err = repo.StatementEntries(ctx, wallet, day.AddDate(0, 0, -2), day.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		assert.True(t, opening.IsZero(), "nothing booked before")
+		assert.Empty(t, entries)
+	})
+}
+
+// testDelivery is an unsaved pending delivery of sch's statement for
+// period, due at due.
+func testDelivery(sch *domain.StatementSchedule, period, due time.Time) *domain.StatementDelivery {
+	return &domain.StatementDelivery{
+		ID:            uuid.New(),
+		ScheduleID:    &sch.ID,
+		OwnerID:       sch.OwnerID,
+		WalletID:      sch.WalletID,
+		Period:        period,
+		Format:        sch.Format,
+		Channel:       sch.Channel,
+		Status:        domain.StatementDeliveryPending,
+		NextAttemptAt: &due,
+		CreatedAt:     due,
+		UpdatedAt:     due,
+	}
+}
+
+// testEntry books a credit of amount to walletID at bookedAt and removes
+// it after the test, before the wallet and transaction go.
+func testEntry(t *testing.T, db *sqlx.DB, txID, walletID uuid.UUID, amount, balanceAfter int64, bookedAt time.Time) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	_, err := db.Exec(`
+		INSERT INTO customer_schema.ledger_entries (id, transaction_id, wallet_id, entry_type, amount, currency, balance_after, created_at, previous_hash, hash)
+		VALUES ($1, $2, $3, 'credit', $4, 'MWK', $5, $6, '', $7)
+	`, id, txID, walletID, amount, balanceAfter, bookedAt, id.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Exec(`DELETE FROM customer_schema.ledger_entries WHERE id = $1`, id) })
+	return id
+}
+
+func scheduleIDs(schedules []domain.StatementSchedule) []uuid.UUID {
+	ids := make([]uuid.UUID, len(schedules))
+	for i, s := range schedules {
+		ids[i] = s.ID
+	}
+	return ids
+}
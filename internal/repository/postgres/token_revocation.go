@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"kyd/pkg/errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TokenRevocationRepository keeps revoked tokens so they are refused while
+// Redis is down; see middleware.TokenRevocationStore.
+type TokenRevocationRepository struct {
+	db *sqlx.DB
+}
+
+func NewTokenRevocationRepository(db *sqlx.DB) *TokenRevocationRepository {
+	return &TokenRevocationRepository{db: db}
+}
+
+// Revoke records keyHash until expiresAt, clearing expired rows first.
+func (r *TokenRevocationRepository) Revoke(ctx context.Context, keyHash string, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `
+		DELETE FROM customer_schema.revoked_tokens WHERE expires_at <= NOW()
+	`); err != nil {
+		return errors.Wrap(err, "failed to clear expired revoked tokens")
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_schema.revoked_tokens (key_hash, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (key_hash) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+	`, keyHash, expiresAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke token")
+	}
+	return nil
+}
+
+// IsRevoked reports whether keyHash is revoked and not yet expired.
+func (r *TokenRevocationRepository) IsRevoked(ctx context.Context, keyHash string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM customer_schema.revoked_tokens
+			WHERE key_hash = $1 AND expires_at > NOW()
+		)
+	`, keyHash).Scan(&revoked)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check revoked token")
+	}
+	return revoked, nil
+}
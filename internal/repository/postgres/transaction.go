@@ -9,6 +9,7 @@ import (
 
 	"kyd/internal/domain"
 	"kyd/pkg/errors"
+	"kyd/pkg/reference"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -17,45 +18,123 @@ import (
 )
 
 type TransactionRepository struct {
-	db *sqlx.DB
+	db        *instrumentedDB
+	outbox    bool
+	listeners []TransactionListener
+}
+
+// TransactionListener hears about every transaction Create or Update
+// stores, once the write has succeeded.
+type TransactionListener interface {
+	TransactionStored(ctx context.Context, tx *domain.Transaction)
 }
 
 func NewTransactionRepository(db *sqlx.DB) *TransactionRepository {
-	return &TransactionRepository{db: db}
+	return &TransactionRepository{db: instrument(db)}
+}
+
+// WithOutbox writes payment.completed to the outbox, in the same database
+// transaction, whenever a transaction is stored as completed.
+func (r *TransactionRepository) WithOutbox() *TransactionRepository {
+	r.outbox = true
+	return r
+}
+
+// WithListener reports each transaction Create and Update store to l.
+// Changes made by the other write methods, or outside this repository, are
+// not reported; listeners needing all of them also poll FindChangedSince.
+func (r *TransactionRepository) WithListener(l TransactionListener) *TransactionRepository {
+	r.listeners = append(r.listeners, l)
+	return r
 }
 
+func (r *TransactionRepository) stored(ctx context.Context, tx *domain.Transaction) {
+	for _, l := range r.listeners {
+		l.TransactionStored(ctx, tx)
+	}
+}
+
+// referenceNumberAttempts bounds retries when a generated reference number
+// collides with an existing one.
+const referenceNumberAttempts = 3
+
+// Create inserts tx, assigning it a reference number when it has none.
 func (r *TransactionRepository) Create(ctx context.Context, tx *domain.Transaction) error {
 	query := `
         INSERT INTO customer_schema.transactions (
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+            id, reference, reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
             amount, currency, exchange_rate, converted_amount, converted_currency,
             fee_amount, fee_currency, net_amount, status, status_reason, transaction_type,
             channel, category, description, metadata, blockchain_tx_hash,
             settlement_id, initiated_at, completed_at, created_at, updated_at
         ) VALUES (
             $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
-            $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27
+            $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28
         )
     `
 
-	_, err := r.db.ExecContext(ctx, query,
-		tx.ID, tx.Reference, tx.SenderID, tx.ReceiverID, tx.SenderWalletID, tx.ReceiverWalletID,
-		tx.Amount, tx.Currency, tx.ExchangeRate, tx.ConvertedAmount, tx.ConvertedCurrency,
-		tx.FeeAmount, tx.FeeCurrency, tx.NetAmount, tx.Status, tx.StatusReason, tx.TransactionType,
-		tx.Channel, tx.Category, tx.Description, tx.Metadata, tx.BlockchainTxHash,
-		tx.SettlementID, tx.InitiatedAt, tx.CompletedAt, tx.CreatedAt, tx.UpdatedAt,
-	)
+	generated := tx.ReferenceNumber == ""
+	for attempt := 1; ; attempt++ {
+		if generated {
+			ref, err := reference.New()
+			if err != nil {
+				return errors.Wrap(err, "failed to generate reference number")
+			}
+			tx.ReferenceNumber = ref
+		}
+
+		args := []interface{}{
+			tx.ID, tx.Reference, tx.ReferenceNumber, tx.SenderID, tx.ReceiverID, tx.SenderWalletID, tx.ReceiverWalletID,
+			tx.Amount, tx.Currency, tx.ExchangeRate, tx.ConvertedAmount, tx.ConvertedCurrency,
+			tx.FeeAmount, tx.FeeCurrency, tx.NetAmount, tx.Status, tx.StatusReason, tx.TransactionType,
+			tx.Channel, tx.Category, tx.Description, tx.Metadata, tx.BlockchainTxHash,
+			tx.SettlementID, tx.InitiatedAt, tx.CompletedAt, tx.CreatedAt, tx.UpdatedAt,
+		}
+		var err error
+		if r.outbox && tx.Status == domain.TransactionStatusCompleted {
+			err = r.withPaymentCompleted(ctx, tx, func(dbtx *sqlx.Tx) (sql.Result, error) {
+				return dbtx.ExecContext(ctx, query, args...)
+			})
+		} else {
+			_, err = r.db.ExecContext(ctx, query, args...)
+		}
+		if err == nil {
+			r.stored(ctx, tx)
+			return nil
+		}
 
-	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			if pqErr.Constraint == "uq_tx_reference_number" {
+				if generated && attempt < referenceNumberAttempts {
+					continue
+				}
+				return errors.Wrap(err, "reference number already in use")
+			}
 			if strings.Contains(pqErr.Constraint, "reference") || strings.Contains(pqErr.Message, "reference") {
 				return errors.ErrTransactionAlreadyExists
 			}
 		}
 		return errors.Wrap(err, "failed to create transaction")
 	}
+}
 
-	return nil
+// transactionByReferenceNumberQuery is served by uq_tx_reference_number.
+const transactionByReferenceNumberQuery = `SELECT ` + transactionColumns + `
+		FROM customer_schema.transactions WHERE reference_number = $1`
+
+// FindByReferenceNumber looks a transaction up by its canonical reference
+// number (see reference.Parse).
+func (r *TransactionRepository) FindByReferenceNumber(ctx context.Context, ref string) (*domain.Transaction, error) {
+	var tx domain.Transaction
+	err := r.db.GetContext(ctx, &tx, transactionByReferenceNumberQuery, ref)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find transaction")
+	}
+
+	return &tx, nil
 }
 
 func (r *TransactionRepository) Update(ctx context.Context, tx *domain.Transaction) error {
@@ -66,14 +145,56 @@ func (r *TransactionRepository) Update(ctx context.Context, tx *domain.Transacti
 			description = $7
 		WHERE id = $8
 	`
-
-	_, err := r.db.ExecContext(ctx, query,
+	args := []interface{}{
 		tx.Status, tx.StatusReason, tx.BlockchainTxHash,
 		tx.SettlementID, tx.CompletedAt, tx.UpdatedAt, tx.Description,
 		tx.ID,
-	)
+	}
 
-	return errors.Wrap(err, "failed to update transaction")
+	var err error
+	if r.outbox && tx.Status == domain.TransactionStatusCompleted {
+		err = r.withPaymentCompleted(ctx, tx, func(dbtx *sqlx.Tx) (sql.Result, error) {
+			return dbtx.ExecContext(ctx, query, args...)
+		})
+	} else {
+		_, err = r.db.ExecContext(ctx, query, args...)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update transaction")
+	}
+	r.stored(ctx, tx)
+	return nil
+}
+
+// withPaymentCompleted runs write, which stores tx as completed, in a
+// database transaction that also writes payment.completed unless tx was
+// already completed or write stored nothing.
+func (r *TransactionRepository) withPaymentCompleted(ctx context.Context, tx *domain.Transaction, write func(*sqlx.Tx) (sql.Result, error)) error {
+	dbtx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbtx.Rollback()
+
+	var previous domain.TransactionStatus
+	err = dbtx.GetContext(ctx, &previous, `SELECT status FROM customer_schema.transactions WHERE id = $1 FOR UPDATE`, tx.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	res, err := write(dbtx)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 && previous != domain.TransactionStatusCompleted {
+		event, err := domain.PaymentCompleted(tx)
+		if err != nil {
+			return err
+		}
+		if err := writeOutbox(ctx, dbtx, event); err != nil {
+			return err
+		}
+	}
+	return dbtx.Commit()
 }
 
 func (r *TransactionRepository) Flag(ctx context.Context, id uuid.UUID, reason string) error {
@@ -94,11 +215,18 @@ func (r *TransactionRepository) Flag(ctx context.Context, id uuid.UUID, reason s
 	return errors.Wrap(err, "failed to flag transaction")
 }
 
+// SetMetadata replaces a transaction's metadata.
+func (r *TransactionRepository) SetMetadata(ctx context.Context, id uuid.UUID, metadata domain.Metadata) error {
+	query := `UPDATE customer_schema.transactions SET metadata = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, metadata, id)
+	return errors.Wrap(err, "failed to update transaction metadata")
+}
+
 func (r *TransactionRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
 	var tx domain.Transaction
 	query := `
 		SELECT 
-			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
 			amount, currency, exchange_rate, converted_amount, converted_currency,
 			fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
 			status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
@@ -122,7 +250,7 @@ func (r *TransactionRepository) FindByReference(ctx context.Context, ref string)
 	var tx domain.Transaction
 	query := `
 		SELECT 
-			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
 			amount, currency, exchange_rate, converted_amount, converted_currency,
 			fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
 			status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
@@ -142,23 +270,67 @@ func (r *TransactionRepository) FindByReference(ctx context.Context, ref string)
 	return &tx, nil
 }
 
-func (r *TransactionRepository) FindByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, error) {
-	var txs []*domain.Transaction
-	query := `
-		SELECT 
-			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+// transactionColumns is the select list shared by the paginated listings.
+const transactionColumns = `
+			id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
 			amount, currency, exchange_rate, converted_amount, converted_currency,
 			fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
 			status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
 			metadata, COALESCE(blockchain_tx_hash, '') AS blockchain_tx_hash, settlement_id, initiated_at, completed_at,
-			created_at, updated_at
-		FROM customer_schema.transactions 
-		WHERE sender_id = $1 OR receiver_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+			created_at, updated_at`
+
+// userTransactionsQuery lists a user's transactions newest first. The sent
+// and received sides are read as separate branches so each walks its own
+// (party, created_at, id) index instead of the planner falling back to a
+// scan for the OR; self-transfers are only taken from the sent side. With
+// keyset set, $2 and $3 are the created_at and id of the last row already
+// returned and $4 is the page size; otherwise $2 and $3 are limit and offset.
+func userTransactionsQuery(keyset bool) string {
+	branch := func(party, extra string) string {
+		where := party + " = $1" + extra
+		limit := "$2::bigint + $3::bigint"
+		if keyset {
+			where += " AND (created_at, id) < ($2, $3)"
+			limit = "$4"
+		}
+		return `(SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE ` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + limit + `)`
+	}
+	page := "LIMIT $2 OFFSET $3"
+	if keyset {
+		page = "LIMIT $4"
+	}
+	return `
+		SELECT * FROM (
+		` + branch("sender_id", "") + `
+		UNION ALL
+		` + branch("receiver_id", " AND sender_id <> $1") + `
+		) AS t
+		ORDER BY created_at DESC, id DESC
+		` + page
+}
+
+func (r *TransactionRepository) FindByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, error) {
+	var txs []*domain.Transaction
+	err := r.db.SelectContext(ctx, &txs, userTransactionsQuery(false), userID, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find transactions")
+	}
 
-	err := r.db.SelectContext(ctx, &txs, query, userID, limit, offset)
+	return txs, nil
+}
+
+// FindByUserIDAfter returns up to limit of the user's transactions older than
+// the cursor, newest first. A nil cursor starts from the most recent.
+func (r *TransactionRepository) FindByUserIDAfter(ctx context.Context, userID uuid.UUID, after *domain.TransactionCursor, limit int) ([]*domain.Transaction, error) {
+	if after == nil {
+		return r.FindByUserID(ctx, userID, limit, 0)
+	}
+	var txs []*domain.Transaction
+	err := r.db.SelectContext(ctx, &txs, userTransactionsQuery(true), userID, after.CreatedAt, after.ID, limit)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to find transactions")
 	}
@@ -166,6 +338,50 @@ func (r *TransactionRepository) FindByUserID(ctx context.Context, userID uuid.UU
 	return txs, nil
 }
 
+// changedSinceQuery walks idx_transactions_updated_at in order of last
+// change; ($1, $2) is the updated_at and id of the last row already read.
+const changedSinceQuery = `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE (updated_at, id) > ($1, $2)
+		ORDER BY updated_at, id
+		LIMIT $3`
+
+// FindChangedSince returns up to limit transactions changed after the given
+// position, oldest change first. Start from (since, uuid.Nil).
+func (r *TransactionRepository) FindChangedSince(ctx context.Context, since time.Time, afterID uuid.UUID, limit int) ([]*domain.Transaction, error) {
+	var txs []*domain.Transaction
+	if err := r.db.SelectContext(ctx, &txs, changedSinceQuery, since, afterID, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to find changed transactions")
+	}
+	return txs, nil
+}
+
+// pendingBySenderQuery lists a sender's transactions in any of the statuses
+// in $2, newest first, walking the (sender_id, created_at, id) index.
+const pendingBySenderQuery = `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE sender_id = $1 AND status = ANY($2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`
+
+// FindPendingBySender returns up to limit of the payments the user sent that
+// have not gone out yet, newest first.
+func (r *TransactionRepository) FindPendingBySender(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Transaction, error) {
+	statuses := make([]string, len(domain.PendingTransactionStatuses))
+	for i, st := range domain.PendingTransactionStatuses {
+		statuses[i] = string(st)
+	}
+	var txs []*domain.Transaction
+	err := r.db.SelectContext(ctx, &txs, pendingBySenderQuery, userID, pq.Array(statuses), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find pending transactions")
+	}
+
+	return txs, nil
+}
+
 func (r *TransactionRepository) GetTransactionVolume(ctx context.Context, months int) ([]*domain.TransactionVolume, error) {
 	var volumes []*domain.TransactionVolume
 	query := `
@@ -212,23 +428,41 @@ func (r *TransactionRepository) GetSystemStats(ctx context.Context) (*domain.Sys
 	return &stats, nil
 }
 
-func (r *TransactionRepository) FindByStatus(ctx context.Context, status domain.TransactionStatus, limit, offset int) ([]*domain.Transaction, error) {
-	var txs []*domain.Transaction
-	query := `
-        SELECT 
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
-            amount, currency, exchange_rate, converted_amount, converted_currency,
-            fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
-			status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
-			metadata, COALESCE(blockchain_tx_hash, '') AS blockchain_tx_hash, settlement_id, initiated_at, completed_at,
-			created_at, updated_at
-		FROM customer_schema.transactions 
+const (
+	transactionsByStatusQuery = `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
 		WHERE status = $1
-		ORDER BY created_at ASC
+		ORDER BY created_at ASC, id ASC
 		LIMIT $2 OFFSET $3
-    `
+	`
+	transactionsByStatusAfterQuery = `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE status = $1 AND (created_at, id) > ($2, $3)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $4
+	`
+)
 
-	err := r.db.SelectContext(ctx, &txs, query, status, limit, offset)
+func (r *TransactionRepository) FindByStatus(ctx context.Context, status domain.TransactionStatus, limit, offset int) ([]*domain.Transaction, error) {
+	var txs []*domain.Transaction
+	err := r.db.SelectContext(ctx, &txs, transactionsByStatusQuery, status, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find transactions by status")
+	}
+
+	return txs, nil
+}
+
+// FindByStatusAfter returns up to limit transactions in status created after
+// the cursor, oldest first. A nil cursor starts from the oldest.
+func (r *TransactionRepository) FindByStatusAfter(ctx context.Context, status domain.TransactionStatus, after *domain.TransactionCursor, limit int) ([]*domain.Transaction, error) {
+	if after == nil {
+		return r.FindByStatus(ctx, status, limit, 0)
+	}
+	var txs []*domain.Transaction
+	err := r.db.SelectContext(ctx, &txs, transactionsByStatusAfterQuery, status, after.CreatedAt, after.ID, limit)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to find transactions by status")
 	}
@@ -254,7 +488,7 @@ func (r *TransactionRepository) FindFlagged(ctx context.Context, limit, offset i
 	var txs []*domain.Transaction
 	query := `
         SELECT 
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+            id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
             amount, currency, exchange_rate, converted_amount, converted_currency,
             fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
             status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
@@ -290,7 +524,7 @@ func (r *TransactionRepository) FindByWalletID(ctx context.Context, walletID uui
 	var txs []*domain.Transaction
 	query := `
         SELECT 
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+            id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
             amount, currency, exchange_rate, converted_amount, converted_currency,
             fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
             status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
@@ -310,32 +544,6 @@ func (r *TransactionRepository) FindByWalletID(ctx context.Context, walletID uui
 	return txs, nil
 }
 
-func (r *TransactionRepository) FindStuckPending(ctx context.Context, olderThan time.Duration, limit int) ([]*domain.Transaction, error) {
-	var txs []*domain.Transaction
-	query := `
-        SELECT 
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
-            amount, currency, exchange_rate, converted_amount, converted_currency,
-            fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
-            status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
-            metadata, COALESCE(blockchain_tx_hash, '') AS blockchain_tx_hash, settlement_id, initiated_at, completed_at,
-            created_at, updated_at
-        FROM customer_schema.transactions 
-        WHERE status = 'pending' AND created_at < NOW() - $1::INTERVAL
-        ORDER BY created_at ASC
-        LIMIT $2
-    `
-	// Convert duration to postgres interval string
-	interval := fmt.Sprintf("%d seconds", int(olderThan.Seconds()))
-
-	err := r.db.SelectContext(ctx, &txs, query, interval, limit)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to find stuck pending transactions")
-	}
-
-	return txs, nil
-}
-
 func (r *TransactionRepository) CountByWalletID(ctx context.Context, walletID uuid.UUID) (int, error) {
 	var total int
 	query := `
@@ -354,7 +562,7 @@ func (r *TransactionRepository) FindAll(ctx context.Context, limit, offset int)
 	var txs []*domain.Transaction
 	query := `
         SELECT 
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+            id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
             amount, currency, exchange_rate, converted_amount, converted_currency,
             fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
             status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
@@ -385,7 +593,7 @@ func (r *TransactionRepository) FindAllWithFilters(ctx context.Context, limit, o
 	var txs []*domain.Transaction
 	query := `
         SELECT 
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+            id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
             amount, currency, exchange_rate, converted_amount, converted_currency,
             fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
             status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
@@ -449,23 +657,17 @@ func (r *TransactionRepository) CountAllWithFilters(ctx context.Context, status
 	return total, nil
 }
 
+const pendingSettlementQuery = `
+		SELECT` + transactionColumns + `
+		FROM customer_schema.transactions
+		WHERE status = 'pending_settlement' AND settlement_id IS NULL
+		ORDER BY completed_at ASC, id ASC
+		LIMIT $1
+	`
+
 func (r *TransactionRepository) FindPendingSettlement(ctx context.Context, limit int) ([]*domain.Transaction, error) {
 	var txs []*domain.Transaction
-	query := `
-        SELECT 
-            id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
-            amount, currency, exchange_rate, converted_amount, converted_currency,
-            fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
-            status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
-            metadata, COALESCE(blockchain_tx_hash, '') AS blockchain_tx_hash, settlement_id, initiated_at, completed_at,
-            created_at, updated_at
-        FROM customer_schema.transactions 
-        WHERE status = 'pending_settlement' AND settlement_id IS NULL
-        ORDER BY completed_at ASC
-        LIMIT $1
-    `
-
-	err := r.db.SelectContext(ctx, &txs, query, limit)
+	err := r.db.SelectContext(ctx, &txs, pendingSettlementQuery, limit)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to find pending settlements")
 	}
@@ -477,7 +679,7 @@ func (r *TransactionRepository) FindBySettlementID(ctx context.Context, settleme
 	var txs []*domain.Transaction
 	query := `
 		SELECT 
-			id, reference, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
+			id, reference, COALESCE(reference_number, '') AS reference_number, sender_id, receiver_id, sender_wallet_id, receiver_wallet_id,
 			amount, currency, exchange_rate, converted_amount, converted_currency,
 			fee_amount, COALESCE(fee_currency, '') AS fee_currency, COALESCE(net_amount, converted_amount) AS net_amount,
 			status, COALESCE(status_reason, '') AS status_reason, transaction_type, COALESCE(channel, '') AS channel, COALESCE(category, '') AS category, COALESCE(description, '') AS description,
@@ -609,6 +811,31 @@ func (r *TransactionRepository) SumEarnings(ctx context.Context) (decimal.Decima
 	return total, nil
 }
 
+// FindByType lists transactions of txType newest first, filtered by status
+// when it is non-empty, with the total matching.
+func (r *TransactionRepository) FindByType(ctx context.Context, txType domain.TransactionType, status domain.TransactionStatus, limit, offset int) ([]*domain.Transaction, int, error) {
+	txs := []*domain.Transaction{}
+	err := r.db.SelectContext(ctx, &txs, `
+		SELECT`+transactionColumns+`
+		FROM customer_schema.transactions
+		WHERE transaction_type = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, txType, status, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to find transactions by type")
+	}
+	var total int
+	err = r.db.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM customer_schema.transactions
+		WHERE transaction_type = $1 AND ($2 = '' OR status = $2)
+	`, txType, status)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count transactions by type")
+	}
+	return txs, total, nil
+}
+
 func (r *TransactionRepository) CountByStatus(ctx context.Context, status domain.TransactionStatus) (int, error) {
 	var total int
 	query := `SELECT COUNT(*) FROM customer_schema.transactions WHERE status = $1`
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TransactionEventRepository struct {
+	db *sqlx.DB
+}
+
+func NewTransactionEventRepository(db *sqlx.DB) *TransactionEventRepository {
+	return &TransactionEventRepository{db: db}
+}
+
+func (r *TransactionEventRepository) Append(ctx context.Context, e *domain.TransactionEvent) error {
+	if e.Details == nil {
+		e.Details = domain.Metadata{}
+	}
+	query := `
+		INSERT INTO customer_schema.transaction_events (id, transaction_id, event_type, details, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.db.ExecContext(ctx, query, e.ID, e.TransactionID, e.Type, e.Details, e.CreatedAt)
+	return errors.Wrap(err, "failed to append transaction event")
+}
+
+// ListByTransaction returns a transaction's events in the order they happened.
+func (r *TransactionEventRepository) ListByTransaction(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionEvent, error) {
+	var items []*domain.TransactionEvent
+	query := `
+		SELECT id, transaction_id, event_type, details, created_at
+		FROM customer_schema.transaction_events
+		WHERE transaction_id = $1
+		ORDER BY created_at, id
+	`
+	if err := r.db.SelectContext(ctx, &items, query, txID); err != nil {
+		return nil, errors.Wrap(err, "failed to list transaction events")
+	}
+	return items, nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type TransactionNoteRepository struct {
+	db *sqlx.DB
+}
+
+func NewTransactionNoteRepository(db *sqlx.DB) *TransactionNoteRepository {
+	return &TransactionNoteRepository{db: db}
+}
+
+const transactionNoteColumns = `id, transaction_id, author_id, visibility, body, tags, created_at, updated_at`
+
+func (r *TransactionNoteRepository) UpsertPrivate(ctx context.Context, n *domain.TransactionNote) error {
+	query := `
+		INSERT INTO customer_schema.transaction_notes (` + transactionNoteColumns + `)
+		VALUES ($1, $2, $3, 'private', $4, $5, $6, $6)
+		ON CONFLICT (transaction_id, author_id) WHERE visibility = 'private'
+		DO UPDATE SET body = EXCLUDED.body, tags = EXCLUDED.tags, updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowxContext(ctx, query, n.ID, n.TransactionID, n.AuthorID, n.Body, n.Tags, n.UpdatedAt).
+		Scan(&n.ID, &n.CreatedAt)
+	return errors.Wrap(err, "failed to save transaction note")
+}
+
+func (r *TransactionNoteRepository) DeletePrivate(ctx context.Context, txID, authorID uuid.UUID) error {
+	query := `DELETE FROM customer_schema.transaction_notes WHERE transaction_id = $1 AND author_id = $2 AND visibility = 'private'`
+	_, err := r.db.ExecContext(ctx, query, txID, authorID)
+	return errors.Wrap(err, "failed to delete transaction note")
+}
+
+func (r *TransactionNoteRepository) PrivateNotes(ctx context.Context, authorID uuid.UUID, txIDs []uuid.UUID) (map[uuid.UUID]*domain.TransactionNote, error) {
+	out := make(map[uuid.UUID]*domain.TransactionNote, len(txIDs))
+	if len(txIDs) == 0 {
+		return out, nil
+	}
+	ids := make([]string, len(txIDs))
+	for i, id := range txIDs {
+		ids[i] = id.String()
+	}
+	var items []*domain.TransactionNote
+	query := `
+		SELECT ` + transactionNoteColumns + `
+		FROM customer_schema.transaction_notes
+		WHERE author_id = $1 AND visibility = 'private' AND transaction_id = ANY($2::uuid[])
+	`
+	if err := r.db.SelectContext(ctx, &items, query, authorID, pq.Array(ids)); err != nil {
+		return nil, errors.Wrap(err, "failed to load transaction notes")
+	}
+	for _, n := range items {
+		out[n.TransactionID] = n
+	}
+	return out, nil
+}
+
+func (r *TransactionNoteRepository) SearchPrivate(ctx context.Context, authorID uuid.UUID, query, tag string, limit, offset int) ([]uuid.UUID, int, error) {
+	args := []interface{}{authorID}
+	where := []string{"n.author_id = $1", "n.visibility = 'private'"}
+	if query != "" {
+		args = append(args, "%"+query+"%")
+		where = append(where, fmt.Sprintf("n.body ILIKE $%d", len(args)))
+	}
+	if tag != "" {
+		args = append(args, tag)
+		where = append(where, fmt.Sprintf("$%d = ANY(n.tags)", len(args)))
+	}
+	return r.search(ctx, strings.Join(where, " AND "), args, limit, offset)
+}
+
+func (r *TransactionNoteRepository) AddInternal(ctx context.Context, n *domain.TransactionNote) error {
+	query := `
+		INSERT INTO customer_schema.transaction_notes (` + transactionNoteColumns + `)
+		VALUES ($1, $2, $3, 'internal', $4, '{}', $5, $5)
+	`
+	_, err := r.db.ExecContext(ctx, query, n.ID, n.TransactionID, n.AuthorID, n.Body, n.CreatedAt)
+	return errors.Wrap(err, "failed to add internal note")
+}
+
+func (r *TransactionNoteRepository) ListInternal(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionNote, error) {
+	items := []*domain.TransactionNote{}
+	query := `
+		SELECT ` + transactionNoteColumns + `
+		FROM customer_schema.transaction_notes
+		WHERE transaction_id = $1 AND visibility = 'internal'
+		ORDER BY created_at, id
+	`
+	if err := r.db.SelectContext(ctx, &items, query, txID); err != nil {
+		return nil, errors.Wrap(err, "failed to list internal notes")
+	}
+	return items, nil
+}
+
+func (r *TransactionNoteRepository) SearchInternal(ctx context.Context, query string, limit, offset int) ([]uuid.UUID, int, error) {
+	args := []interface{}{}
+	where := []string{"n.visibility = 'internal'"}
+	if query != "" {
+		args = append(args, "%"+query+"%")
+		where = append(where, fmt.Sprintf("n.body ILIKE $%d", len(args)))
+	}
+	return r.search(ctx, strings.Join(where, " AND "), args, limit, offset)
+}
+
+// search returns the distinct transactions with a note matching where,
+// newest transaction first.
+func (r *TransactionNoteRepository) search(ctx context.Context, where string, args []interface{}, limit, offset int) ([]uuid.UUID, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(DISTINCT n.transaction_id) FROM customer_schema.transaction_notes n WHERE ` + where
+	if err := r.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count note matches")
+	}
+
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT t.id
+		FROM customer_schema.transactions t
+		WHERE t.id IN (SELECT n.transaction_id FROM customer_schema.transaction_notes n WHERE %s)
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args))
+	var ids []uuid.UUID
+	if err := r.db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to search transaction notes")
+	}
+	return ids, total, nil
+}
+
+// ExportInternalNotes returns the internal notes on the given transactions,
+// joined into one field per transaction, for the transaction export.
+func (r *TransactionNoteRepository) ExportInternalNotes(ctx context.Context, txIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	out := make(map[uuid.UUID]string, len(txIDs))
+	if len(txIDs) == 0 {
+		return out, nil
+	}
+	ids := make([]string, len(txIDs))
+	for i, id := range txIDs {
+		ids[i] = id.String()
+	}
+	rows, err := r.db.QueryxContext(ctx, `
+		SELECT transaction_id, string_agg(body, ' | ' ORDER BY created_at, id)
+		FROM customer_schema.transaction_notes
+		WHERE visibility = 'internal' AND transaction_id = ANY($1::uuid[])
+		GROUP BY transaction_id
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to export internal notes")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		var notes string
+		if err := rows.Scan(&id, &notes); err != nil {
+			return nil, errors.Wrap(err, "failed to scan internal notes")
+		}
+		out[id] = notes
+	}
+	return out, errors.Wrap(rows.Err(), "failed to export internal notes")
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TrustedBeneficiaryRepository struct {
+	db *sqlx.DB
+}
+
+func NewTrustedBeneficiaryRepository(db *sqlx.DB) *TrustedBeneficiaryRepository {
+	return &TrustedBeneficiaryRepository{db: db}
+}
+
+const trustedBeneficiaryColumns = `id, user_id, wallet_id, nickname, active_from, created_at`
+
+func (r *TrustedBeneficiaryRepository) Create(ctx context.Context, b *domain.TrustedBeneficiary) error {
+	query := `
+		INSERT INTO customer_schema.trusted_beneficiaries (` + trustedBeneficiaryColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.ExecContext(ctx, query, b.ID, b.UserID, b.WalletID, b.Nickname, b.ActiveFrom, b.CreatedAt)
+	return errors.Wrap(err, "failed to add trusted beneficiary")
+}
+
+func (r *TrustedBeneficiaryRepository) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM customer_schema.trusted_beneficiaries WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to remove trusted beneficiary")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to remove trusted beneficiary")
+	}
+	return n > 0, nil
+}
+
+func (r *TrustedBeneficiaryRepository) List(ctx context.Context, userID uuid.UUID) ([]*domain.TrustedBeneficiary, error) {
+	items := []*domain.TrustedBeneficiary{}
+	query := `
+		SELECT ` + trustedBeneficiaryColumns + `
+		FROM customer_schema.trusted_beneficiaries
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id
+	`
+	if err := r.db.SelectContext(ctx, &items, query, userID); err != nil {
+		return nil, errors.Wrap(err, "failed to list trusted beneficiaries")
+	}
+	return items, nil
+}
+
+func (r *TrustedBeneficiaryRepository) Find(ctx context.Context, userID, walletID uuid.UUID) (*domain.TrustedBeneficiary, error) {
+	var b domain.TrustedBeneficiary
+	query := `SELECT ` + trustedBeneficiaryColumns + ` FROM customer_schema.trusted_beneficiaries WHERE user_id = $1 AND wallet_id = $2`
+	err := r.db.GetContext(ctx, &b, query, userID, walletID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find trusted beneficiary")
+	}
+	return &b, nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"kyd/internal/domain"
 	"kyd/internal/security"
+	"kyd/pkg/clock"
 	"kyd/pkg/errors"
 
 	"github.com/google/uuid"
@@ -19,6 +20,7 @@ import (
 type UserRepository struct {
 	db     *sqlx.DB
 	crypto *security.CryptoService
+	outbox bool
 }
 
 func NewUserRepository(db *sqlx.DB, crypto *security.CryptoService) *UserRepository {
@@ -80,10 +82,10 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 			email_hash, phone_hash, totp_secret, is_totp_enabled,
 			bio, city, postal_code, tax_id, auth_provider, provider_id,
 			profile_picture_url, provider_access_token, provider_refresh_token,
-			email_verified
+			email_verified, password_policy_version, tenant
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
-			$21, $22, $23, $24, $25, $26, $27, $28, $29, $30
+			$21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, COALESCE(NULLIF($32, ''), 'default')
 		)
 	`
 
@@ -93,7 +95,7 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 		user.BusinessName, user.RiskScore, user.IsActive, user.CreatedAt, user.UpdatedAt,
 		emailHash, phoneHash, encTOTPSecret, user.IsTOTPEnabled,
 		user.Bio, user.City, user.PostalCode, user.TaxID, user.AuthProvider, user.ProviderID,
-		encPicture, encAccessToken, encRefreshToken, user.EmailVerified,
+		encPicture, encAccessToken, encRefreshToken, user.EmailVerified, user.PasswordVersion, user.Tenant,
 	)
 
 	if err != nil {
@@ -153,10 +155,10 @@ func (r *UserRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Us
 	var user domain.User
 	query := `
 		SELECT 
-			id, email, phone, password_hash, first_name, last_name,
-			user_type, kyc_level, kyc_status, country_code, date_of_birth,
+			id, email, phone, password_hash, password_policy_version, first_name, last_name,
+			user_type, tenant, kyc_level, kyc_status, country_code, date_of_birth,
 			business_name, business_registration, risk_score, is_active,
-			email_verified, totp_secret, is_totp_enabled, last_login,
+			email_verified, phone_verified, phone_verified_at, totp_secret, is_totp_enabled, login_alerts_disabled, time_zone, admin_role, last_login,
 			failed_login_attempts, locked_until, created_at, updated_at,
 			COALESCE(bio, '') as bio,
 			COALESCE(city, '') as city,
@@ -193,7 +195,7 @@ func (r *UserRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*dom
 		SELECT 
 			id, email, phone, first_name, last_name, user_type, kyc_level, kyc_status,
 			country_code, date_of_birth, business_name, risk_score, is_active,
-			failed_login_attempts, locked_until, last_login, created_at, updated_at, is_totp_enabled,
+			failed_login_attempts, locked_until, last_login, created_at, updated_at, is_totp_enabled, phone_verified,
 			COALESCE(bio, '') as bio,
 			COALESCE(city, '') as city,
 			COALESCE(postal_code, '') as postal_code,
@@ -227,10 +229,10 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain
 	emailHash := r.crypto.BlindIndex(email)
 	query := `
 		SELECT 
-			id, email, phone, password_hash, first_name, last_name,
-			user_type, kyc_level, kyc_status, country_code, date_of_birth,
+			id, email, phone, password_hash, password_policy_version, first_name, last_name,
+			user_type, tenant, kyc_level, kyc_status, country_code, date_of_birth,
 			business_name, business_registration, risk_score, is_active,
-			email_verified, totp_secret, is_totp_enabled, last_login,
+			email_verified, phone_verified, phone_verified_at, totp_secret, is_totp_enabled, login_alerts_disabled, time_zone, admin_role, last_login,
 			failed_login_attempts, locked_until, created_at, updated_at,
 			bio, city, postal_code, tax_id, auth_provider, provider_id
 		FROM customer_schema.users WHERE email_hash = $1`
@@ -245,15 +247,22 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain
 				email,
 				phone,
 				password_hash,
+				password_policy_version,
 				first_name,
 				last_name,
 				user_type,
+				tenant,
 				kyc_level,
 				kyc_status,
 				is_active,
 				email_verified,
+				phone_verified,
+				phone_verified_at,
 				totp_secret,
 				is_totp_enabled,
+				login_alerts_disabled,
+				time_zone,
+				admin_role,
 				last_login,
 				failed_login_attempts,
 				locked_until,
@@ -348,8 +357,10 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 			totp_secret = $15, is_totp_enabled = $16,
 			bio = $17, city = $18, postal_code = $19, tax_id = $20,
 			is_active = $21, auth_provider = $22, provider_id = $23,
-			email_verified = $24
-		WHERE id = $25
+			email_verified = $24, phone_verified = $25, phone_verified_at = $26,
+			password_policy_version = $27, login_alerts_disabled = $28,
+			time_zone = $29
+		WHERE id = $30
 	`
 
 	_, err = r.db.ExecContext(ctx, query,
@@ -359,7 +370,9 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 		user.UpdatedAt, emailHash, phoneHash, encTOTPSecret, user.IsTOTPEnabled,
 		user.Bio, user.City, user.PostalCode, user.TaxID,
 		user.IsActive, user.AuthProvider, user.ProviderID,
-		user.EmailVerified,
+		user.EmailVerified, user.PhoneVerified, user.PhoneVerifiedAt,
+		user.PasswordVersion, user.LoginAlertsDisabled,
+		clock.Location(user.TimeZone).String(),
 		user.ID,
 	)
 
@@ -377,6 +390,18 @@ func (r *UserRepository) SetEmailVerified(ctx context.Context, id uuid.UUID) err
 	return errors.Wrap(err, "failed to set email verified")
 }
 
+func (r *UserRepository) SetPhoneVerified(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE customer_schema.users SET
+			phone_verified = TRUE,
+			phone_verified_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return errors.Wrap(err, "failed to set phone verified")
+}
+
 func (r *UserRepository) UpdateLoginSecurity(ctx context.Context, id uuid.UUID, attempts int, lockedUntil *time.Time) error {
 	query := `
 		UPDATE customer_schema.users SET
@@ -555,6 +580,48 @@ func (r *UserRepository) UpdateKYCStatus(ctx context.Context, userID uuid.UUID,
 			updated_at = NOW()
 		WHERE id = $2
 	`
+	if r.outbox && status == domain.KYCStatusVerified {
+		return errors.Wrap(r.approveKYC(ctx, userID, query), "failed to update kyc status")
+	}
 	_, err := r.db.ExecContext(ctx, query, status, userID)
 	return errors.Wrap(err, "failed to update kyc status")
 }
+
+// WithOutbox writes kyc.approved to the outbox, in the same database
+// transaction, when a user's KYC is verified.
+func (r *UserRepository) WithOutbox() *UserRepository {
+	r.outbox = true
+	return r
+}
+
+// approveKYC runs the update verifying userID's KYC together with its
+// kyc.approved event, unless the user was verified already.
+func (r *UserRepository) approveKYC(ctx context.Context, userID uuid.UUID, query string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous domain.KYCStatus
+	err = tx.GetContext(ctx, &previous, `SELECT COALESCE(kyc_status, '') FROM customer_schema.users WHERE id = $1 FOR UPDATE`, userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, domain.KYCStatusVerified, userID); err != nil {
+		return err
+	}
+	if previous != domain.KYCStatusVerified {
+		event, err := domain.KYCApproved(userID, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := writeOutbox(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
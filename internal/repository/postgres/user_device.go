@@ -9,6 +9,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// AddDevice records a sign-in from device, creating it on first use, and
+// sets its ID and trust from the stored record. A device that is trusted
+// stays trusted.
 func (r *UserRepository) AddDevice(ctx context.Context, device *domain.UserDevice) error {
 	query := `
 		INSERT INTO customer_schema.user_devices (
@@ -20,13 +23,76 @@ func (r *UserRepository) AddDevice(ctx context.Context, device *domain.UserDevic
 			last_seen_at = EXCLUDED.last_seen_at,
 			ip_address = EXCLUDED.ip_address,
 			country_code = COALESCE(EXCLUDED.country_code, customer_schema.user_devices.country_code),
-			device_name = COALESCE(EXCLUDED.device_name, customer_schema.user_devices.device_name)
+			device_name = COALESCE(EXCLUDED.device_name, customer_schema.user_devices.device_name),
+			is_trusted = customer_schema.user_devices.is_trusted OR EXCLUDED.is_trusted
+		RETURNING id, is_trusted
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	return r.db.QueryRowxContext(ctx, query,
 		device.UserID, device.DeviceHash, device.DeviceName, device.IPAddress, device.CountryCode,
 		device.IsTrusted, device.LastSeenAt, device.CreatedAt,
-	)
-	return err
+	).Scan(&device.ID, &device.IsTrusted)
+}
+
+const userDeviceColumns = `
+	id, user_id, device_hash, device_name, label, country_code, ip_address, is_trusted, last_seen_at, created_at
+`
+
+// ListDevices returns the user's devices, most recently used first.
+func (r *UserRepository) ListDevices(ctx context.Context, userID uuid.UUID) ([]domain.UserDevice, error) {
+	devices := []domain.UserDevice{}
+	query := `SELECT ` + userDeviceColumns + ` FROM customer_schema.user_devices WHERE user_id = $1 ORDER BY last_seen_at DESC`
+	if err := r.db.SelectContext(ctx, &devices, query, userID); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// GetDevice returns one of the user's devices, or nil if they have no such
+// device.
+func (r *UserRepository) GetDevice(ctx context.Context, userID, id uuid.UUID) (*domain.UserDevice, error) {
+	var device domain.UserDevice
+	query := `SELECT ` + userDeviceColumns + ` FROM customer_schema.user_devices WHERE user_id = $1 AND id = $2`
+	err := r.db.GetContext(ctx, &device, query, userID, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// LabelDevice names one of the user's devices, reporting false if they have
+// no such device.
+func (r *UserRepository) LabelDevice(ctx context.Context, userID, id uuid.UUID, label *string) (bool, error) {
+	query := `UPDATE customer_schema.user_devices SET label = $3 WHERE user_id = $1 AND id = $2`
+	return r.execDevice(ctx, query, userID, id, label)
+}
+
+// SetDeviceTrusted trusts or untrusts one of the user's devices, reporting
+// false if they have no such device.
+func (r *UserRepository) SetDeviceTrusted(ctx context.Context, userID, id uuid.UUID, trusted bool) (bool, error) {
+	query := `UPDATE customer_schema.user_devices SET is_trusted = $3 WHERE user_id = $1 AND id = $2`
+	return r.execDevice(ctx, query, userID, id, trusted)
+}
+
+// DeleteDevice forgets one of the user's devices, reporting false if they
+// have no such device. Signing in from it again records it as new.
+func (r *UserRepository) DeleteDevice(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `DELETE FROM customer_schema.user_devices WHERE user_id = $1 AND id = $2`
+	return r.execDevice(ctx, query, userID, id)
+}
+
+func (r *UserRepository) execDevice(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
 }
 
 func (r *UserRepository) IsCountryTrusted(ctx context.Context, userID uuid.UUID, countryCode string) (bool, error) {
@@ -45,6 +111,21 @@ func (r *UserRepository) IsCountryTrusted(ctx context.Context, userID uuid.UUID,
 	return trusted, nil
 }
 
+// HasDevice reports whether the user has logged in from the device before.
+func (r *UserRepository) HasDevice(ctx context.Context, userID uuid.UUID, deviceHash string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM customer_schema.user_devices
+			WHERE user_id = $1 AND device_hash = $2
+		)
+	`
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, query, userID, deviceHash); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
 func (r *UserRepository) IsDeviceTrusted(ctx context.Context, userID uuid.UUID, deviceHash string) (bool, error) {
 	query := `
 		SELECT is_trusted FROM customer_schema.user_devices
@@ -0,0 +1,250 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/security"
+	"kyd/internal/userimport"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// userImportLease is how long an import may go without progress in
+// processing before another worker assumes the previous one died and
+// resumes it.
+const userImportLease = 5 * time.Minute
+
+const userImportColumns = `
+	id, file_name, organization, status, total_rows, invalid_rows, processed_rows,
+	invited_rows, failed_rows, created_by, created_at, started_at, completed_at, updated_at
+`
+
+const userImportRowColumns = `import_id, line, record, status, errors, user_id, processed_at`
+
+// UserImportRepository stores bulk user imports. Rows hold personal data,
+// so their records are stored encrypted.
+type UserImportRepository struct {
+	db     *sqlx.DB
+	crypto *security.CryptoService
+}
+
+func NewUserImportRepository(db *sqlx.DB, crypto *security.CryptoService) *UserImportRepository {
+	return &UserImportRepository{db: db, crypto: crypto}
+}
+
+type userImportRow struct {
+	domain.UserImportRow
+	EncRecord string `db:"record"`
+}
+
+func (r *UserImportRepository) toRows(items []userImportRow) ([]domain.UserImportRow, error) {
+	out := make([]domain.UserImportRow, 0, len(items))
+	for _, item := range items {
+		plain, err := r.crypto.Decrypt(item.EncRecord)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt user import record")
+		}
+		row := item.UserImportRow
+		if err := json.Unmarshal([]byte(plain), &row.Record); err != nil {
+			return nil, errors.Wrap(err, "failed to decode user import record")
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+func (r *UserImportRepository) CreateImport(ctx context.Context, imp *domain.UserImport, rows []domain.UserImportRow) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO admin_schema.user_imports (`+userImportColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+	`, imp.ID, imp.FileName, imp.Organization, imp.Status, imp.TotalRows, imp.InvalidRows, imp.ProcessedRows,
+		imp.InvitedRows, imp.FailedRows, imp.CreatedBy, imp.CreatedAt, imp.StartedAt, imp.CompletedAt, imp.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to create user import")
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO admin_schema.user_import_rows (`+userImportRowColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+	`)
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare user import rows")
+	}
+	defer stmt.Close()
+	for i := range rows {
+		row := &rows[i]
+		plain, err := json.Marshal(row.Record)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode user import record")
+		}
+		enc, err := r.crypto.Encrypt(string(plain))
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt user import record")
+		}
+		if _, err := stmt.ExecContext(ctx, row.ImportID, row.Line, enc, row.Status, row.Errors, row.UserID, row.ProcessedAt); err != nil {
+			return errors.Wrap(err, "failed to create user import row")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit user import")
+}
+
+func (r *UserImportRepository) GetImport(ctx context.Context, id uuid.UUID) (*domain.UserImport, error) {
+	var imp domain.UserImport
+	err := r.db.GetContext(ctx, &imp, `SELECT `+userImportColumns+` FROM admin_schema.user_imports WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, userimport.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user import")
+	}
+	return &imp, nil
+}
+
+func (r *UserImportRepository) ListImports(ctx context.Context, limit, offset int) ([]domain.UserImport, int, error) {
+	items := []domain.UserImport{}
+	query := `
+		SELECT ` + userImportColumns + `
+		FROM admin_schema.user_imports
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`
+	if err := r.db.SelectContext(ctx, &items, query, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list user imports")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.user_imports`); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count user imports")
+	}
+	return items, total, nil
+}
+
+func (r *UserImportRepository) ListRows(ctx context.Context, importID uuid.UUID, status domain.UserImportRowStatus, limit, offset int) ([]domain.UserImportRow, int, error) {
+	where := "WHERE import_id = $1"
+	args := []interface{}{importID}
+	if status != "" {
+		where += " AND status = $2"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_schema.user_import_rows `+where, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count user import rows")
+	}
+
+	var items []userImportRow
+	query := fmt.Sprintf(`SELECT %s FROM admin_schema.user_import_rows %s ORDER BY line LIMIT $%d OFFSET $%d`,
+		userImportRowColumns, where, len(args)+1, len(args)+2)
+	if err := r.db.SelectContext(ctx, &items, query, append(args, limit, offset)...); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list user import rows")
+	}
+	rows, err := r.toRows(items)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+func (r *UserImportRepository) ClaimImport(ctx context.Context, now time.Time) (*domain.UserImport, error) {
+	var imp domain.UserImport
+	query := `
+		UPDATE admin_schema.user_imports
+		SET status = $1, started_at = COALESCE(started_at, $3), updated_at = $3
+		WHERE id = (
+			SELECT id FROM admin_schema.user_imports
+			WHERE status = $2
+			   OR (status = $1 AND updated_at < $4)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + userImportColumns
+	err := r.db.GetContext(ctx, &imp, query,
+		domain.UserImportProcessing, domain.UserImportQueued, now, now.Add(-userImportLease))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim user import")
+	}
+	return &imp, nil
+}
+
+func (r *UserImportRepository) PendingRows(ctx context.Context, importID uuid.UUID, limit int) ([]domain.UserImportRow, error) {
+	var items []userImportRow
+	query := `
+		SELECT ` + userImportRowColumns + `
+		FROM admin_schema.user_import_rows
+		WHERE import_id = $1 AND status = $2
+		ORDER BY line
+		LIMIT $3
+	`
+	if err := r.db.SelectContext(ctx, &items, query, importID, domain.UserImportRowPending, limit); err != nil {
+		return nil, errors.Wrap(err, "failed to list pending user import rows")
+	}
+	return r.toRows(items)
+}
+
+func (r *UserImportRepository) FinishRow(ctx context.Context, row *domain.UserImportRow) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE admin_schema.user_import_rows
+		SET status = $3, errors = $4, user_id = $5, processed_at = $6
+		WHERE import_id = $1 AND line = $2 AND status = $7
+	`, row.ImportID, row.Line, row.Status, row.Errors, row.UserID, row.ProcessedAt, domain.UserImportRowPending)
+	if err != nil {
+		return errors.Wrap(err, "failed to finish user import row")
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// Already finished by another worker; counting it again would skew
+		// the import's progress.
+		return errors.Wrap(err, "failed to finish user import row")
+	}
+
+	invited, failed := 0, 0
+	if row.Status == domain.UserImportRowInvited {
+		invited = 1
+	} else {
+		failed = 1
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE admin_schema.user_imports
+		SET processed_rows = processed_rows + 1,
+		    invited_rows = invited_rows + $2,
+		    failed_rows = failed_rows + $3,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, row.ImportID, invited, failed)
+	if err != nil {
+		return errors.Wrap(err, "failed to count user import row")
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit user import row")
+}
+
+func (r *UserImportRepository) CompleteImport(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE admin_schema.user_imports
+		SET status = $2, completed_at = $3, updated_at = $3
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, domain.UserImportCompleted, at)
+	return errors.Wrap(err, "failed to complete user import")
+}
@@ -17,11 +17,11 @@ import (
 )
 
 type WalletRepository struct {
-	db *sqlx.DB
+	db *instrumentedDB
 }
 
 func NewWalletRepository(db *sqlx.DB) *WalletRepository {
-	return &WalletRepository{db: db}
+	return &WalletRepository{db: instrument(db)}
 }
 
 func (r *WalletRepository) Create(ctx context.Context, wallet *domain.Wallet) error {
@@ -130,6 +130,47 @@ func (r *WalletRepository) ReserveFunds(ctx context.Context, walletID uuid.UUID,
 	return nil
 }
 
+// ReleaseReservedFunds moves funds from reserved back to available balance,
+// undoing ReserveFunds (e.g. an escrow refund).
+func (r *WalletRepository) ReleaseReservedFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error {
+	query := `
+		UPDATE customer_schema.wallets SET
+			available_balance = available_balance + $1,
+			reserved_balance = reserved_balance - $1,
+			updated_at = NOW()
+		WHERE id = $2 AND reserved_balance >= $1
+	`
+	return r.updateReserved(ctx, query, walletID, amount, "failed to release reserved funds")
+}
+
+// DebitReservedFunds pays reserved funds out of the wallet (e.g. an escrow
+// released to its receiver): reserved and ledger balance both drop.
+func (r *WalletRepository) DebitReservedFunds(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) error {
+	query := `
+		UPDATE customer_schema.wallets SET
+			reserved_balance = reserved_balance - $1,
+			ledger_balance = ledger_balance - $1,
+			updated_at = NOW()
+		WHERE id = $2 AND reserved_balance >= $1
+	`
+	return r.updateReserved(ctx, query, walletID, amount, "failed to debit reserved funds")
+}
+
+func (r *WalletRepository) updateReserved(ctx context.Context, query string, walletID uuid.UUID, amount decimal.Decimal, msg string) error {
+	result, err := r.db.ExecContext(ctx, query, amount, walletID)
+	if err != nil {
+		return errors.Wrap(err, msg)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get rows affected")
+	}
+	if rows == 0 {
+		return errors.ErrInsufficientBalance
+	}
+	return nil
+}
+
 func (r *WalletRepository) FindByAddress(ctx context.Context, address string) (*domain.Wallet, error) {
 	wallet := &domain.Wallet{}
 	address = strings.TrimSpace(address)
@@ -311,3 +352,82 @@ func digitalWalletNumberFromID(id uuid.UUID) string {
 	digits := nonDigitsForWalletLookup.ReplaceAllString(mapped, "")
 	return (digits + "4539102834756192")[:16]
 }
+
+// paymentPartiesQuery returns up to two rows: the sender's wallet in the
+// payment currency joined to the sender's KYC fields, and the receiver's
+// wallet by address ($3) or by user and currency ($4, $5). Both carry their
+// owner's country.
+const paymentPartiesQuery = `
+	(SELECT 'sender' AS side, w.id, w.user_id, w.wallet_address, w.currency,
+		w.available_balance, w.ledger_balance, w.reserved_balance, w.status,
+		w.last_transaction_at, w.created_at, w.updated_at,
+		u.kyc_status, u.kyc_level, u.created_at AS user_created_at, u.user_type, u.country_code
+	FROM customer_schema.wallets w
+	JOIN customer_schema.users u ON u.id = w.user_id
+	WHERE w.user_id = $1 AND w.currency = $2
+	LIMIT 1)
+	UNION ALL
+	(SELECT 'receiver', w.id, w.user_id, w.wallet_address, w.currency,
+		w.available_balance, w.ledger_balance, w.reserved_balance, w.status,
+		w.last_transaction_at, w.created_at, w.updated_at,
+		NULL, NULL, NULL, NULL, u.country_code
+	FROM customer_schema.wallets w
+	LEFT JOIN customer_schema.users u ON u.id = w.user_id
+	WHERE ($3 <> '' AND REPLACE(w.wallet_address, ' ', '') = REPLACE($3, ' ', ''))
+		OR ($3 = '' AND w.user_id = $4 AND w.currency = $5)
+	LIMIT 1)`
+
+type paymentPartyRow struct {
+	Side string `db:"side"`
+	domain.Wallet
+	KYCStatus     *domain.KYCStatus `db:"kyc_status"`
+	KYCLevel      *int              `db:"kyc_level"`
+	UserCreatedAt *time.Time        `db:"user_created_at"`
+	UserType      *domain.UserType  `db:"user_type"`
+	CountryCode   *string           `db:"country_code"`
+}
+
+// FindPaymentParties loads the sender, the sender's wallet and the
+// receiver's wallet for a payment in a single query. Missing wallets are
+// left nil rather than reported as errors; receivers addressed by a derived
+// digital wallet number are not matched here (see FindByAddress).
+func (r *WalletRepository) FindPaymentParties(ctx context.Context, q domain.PaymentPartiesQuery) (*domain.PaymentParties, error) {
+	var rows []paymentPartyRow
+	err := r.db.SelectContext(ctx, &rows, paymentPartiesQuery,
+		q.SenderID, q.Currency, strings.TrimSpace(q.ReceiverAddress), q.ReceiverID, q.ReceiverCurrency)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load payment parties")
+	}
+
+	parties := &domain.PaymentParties{}
+	for i := range rows {
+		row := rows[i]
+		wallet := row.Wallet
+		switch row.Side {
+		case "sender":
+			parties.SenderWallet = &wallet
+			parties.Sender = &domain.User{ID: wallet.UserID}
+			if row.KYCStatus != nil {
+				parties.Sender.KYCStatus = *row.KYCStatus
+			}
+			if row.KYCLevel != nil {
+				parties.Sender.KYCLevel = *row.KYCLevel
+			}
+			if row.UserCreatedAt != nil {
+				parties.Sender.CreatedAt = *row.UserCreatedAt
+			}
+			if row.UserType != nil {
+				parties.Sender.UserType = *row.UserType
+			}
+			if row.CountryCode != nil {
+				parties.Sender.CountryCode = *row.CountryCode
+			}
+		case "receiver":
+			parties.ReceiverWallet = &wallet
+			if row.CountryCode != nil {
+				parties.ReceiverCountry = *row.CountryCode
+			}
+		}
+	}
+	return parties, nil
+}
@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/security"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// WalletActivityRepository stores the wallet activity feed. Counterparty
+// names are encrypted at rest like the user records they are copied from.
+type WalletActivityRepository struct {
+	db     *instrumentedDB
+	crypto *security.CryptoService
+}
+
+func NewWalletActivityRepository(db *sqlx.DB, crypto *security.CryptoService) *WalletActivityRepository {
+	return &WalletActivityRepository{db: instrument(db), crypto: crypto}
+}
+
+const walletActivityColumns = `
+			wallet_id, transaction_id, direction, amount, currency, fee_amount,
+			counterparty_id, counterparty_name, counterparty_wallet_number,
+			transaction_type, status, reference_number, description,
+			occurred_at, source_updated_at`
+
+// Upsert writes each row unless the feed already holds a newer version of
+// the same transaction, so projections racing each other settle on the
+// latest state.
+func (r *WalletActivityRepository) Upsert(ctx context.Context, rows []domain.WalletActivity) error {
+	query := `
+		INSERT INTO customer_schema.wallet_activity (` + walletActivityColumns + `, projected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+		ON CONFLICT (wallet_id, transaction_id) DO UPDATE
+		SET direction = EXCLUDED.direction,
+			amount = EXCLUDED.amount,
+			currency = EXCLUDED.currency,
+			fee_amount = EXCLUDED.fee_amount,
+			counterparty_id = EXCLUDED.counterparty_id,
+			counterparty_name = EXCLUDED.counterparty_name,
+			counterparty_wallet_number = EXCLUDED.counterparty_wallet_number,
+			transaction_type = EXCLUDED.transaction_type,
+			status = EXCLUDED.status,
+			reference_number = EXCLUDED.reference_number,
+			description = EXCLUDED.description,
+			occurred_at = EXCLUDED.occurred_at,
+			source_updated_at = EXCLUDED.source_updated_at,
+			projected_at = NOW()
+		WHERE customer_schema.wallet_activity.source_updated_at <= EXCLUDED.source_updated_at
+	`
+	for _, a := range rows {
+		name := a.CounterpartyName
+		if name != "" {
+			enc, err := r.crypto.Encrypt(name)
+			if err != nil {
+				return errors.Wrap(err, "failed to encrypt counterparty name")
+			}
+			name = enc
+		}
+		_, err := r.db.ExecContext(ctx, query,
+			a.WalletID, a.TransactionID, a.Direction, a.Amount, a.Currency, a.FeeAmount,
+			a.CounterpartyID, name, a.CounterpartyWalletNumber,
+			a.TransactionType, a.Status, a.ReferenceNumber, a.Description,
+			a.OccurredAt, a.SourceUpdatedAt,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to project wallet activity")
+		}
+	}
+	return nil
+}
+
+// walletActivityAfterQuery walks idx_wallet_activity_feed from the cursor.
+const walletActivityAfterQuery = `
+		SELECT` + walletActivityColumns + `
+		FROM customer_schema.wallet_activity
+		WHERE wallet_id = $1 AND (occurred_at, transaction_id) < ($2, $3)
+		ORDER BY occurred_at DESC, transaction_id DESC
+		LIMIT $4`
+
+const walletActivityPageQuery = `
+		SELECT` + walletActivityColumns + `
+		FROM customer_schema.wallet_activity
+		WHERE wallet_id = $1
+		ORDER BY occurred_at DESC, transaction_id DESC
+		LIMIT $2 OFFSET $3`
+
+// FindByWalletAfter returns up to limit of the wallet's activity older than
+// the cursor, newest first. A nil cursor starts from the most recent.
+func (r *WalletActivityRepository) FindByWalletAfter(ctx context.Context, walletID uuid.UUID, after *domain.TransactionCursor, limit int) ([]domain.WalletActivity, error) {
+	var rows []domain.WalletActivity
+	var err error
+	if after == nil {
+		err = r.db.SelectContext(ctx, &rows, walletActivityPageQuery, walletID, limit, 0)
+	} else {
+		err = r.db.SelectContext(ctx, &rows, walletActivityAfterQuery, walletID, after.CreatedAt, after.ID, limit)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find wallet activity")
+	}
+	r.decrypt(rows)
+	return rows, nil
+}
+
+// FindByWallet returns a page of the wallet's activity, newest first, and
+// how many rows the wallet has in all.
+func (r *WalletActivityRepository) FindByWallet(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]domain.WalletActivity, int, error) {
+	var rows []domain.WalletActivity
+	if err := r.db.SelectContext(ctx, &rows, walletActivityPageQuery, walletID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to find wallet activity")
+	}
+	var total int
+	query := `SELECT COUNT(*) FROM customer_schema.wallet_activity WHERE wallet_id = $1`
+	if err := r.db.GetContext(ctx, &total, query, walletID); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to count wallet activity")
+	}
+	r.decrypt(rows)
+	return rows, total, nil
+}
+
+// LatestSourceUpdate returns the newest transaction change the feed
+// reflects, or nil while it is empty.
+func (r *WalletActivityRepository) LatestSourceUpdate(ctx context.Context) (*time.Time, error) {
+	var latest sql.NullTime
+	query := `SELECT MAX(source_updated_at) FROM customer_schema.wallet_activity`
+	if err := r.db.GetContext(ctx, &latest, query); err != nil {
+		return nil, errors.Wrap(err, "failed to read wallet activity position")
+	}
+	if !latest.Valid {
+		return nil, nil
+	}
+	return &latest.Time, nil
+}
+
+func (r *WalletActivityRepository) decrypt(rows []domain.WalletActivity) {
+	for i := range rows {
+		if rows[i].CounterpartyName == "" {
+			continue
+		}
+		// A name that does not decrypt is not shown rather than shown as
+		// ciphertext.
+		dec, err := r.crypto.Decrypt(rows[i].CounterpartyName)
+		if err != nil {
+			dec = ""
+		}
+		rows[i].CounterpartyName = dec
+	}
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/security"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletActivityRepository(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	crypto, err := security.NewCryptoService()
+	require.NoError(t, err)
+	repo := NewWalletActivityRepository(db, crypto)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+	userID := testUser(t, db, now)
+	walletID := testWallet(t, db, userID, domain.MWK, domain.WalletStatusActive)
+
+	row := func(txID uuid.UUID, occurredAt, updatedAt time.Time, status domain.TransactionStatus) domain.WalletActivity {
+		return domain.WalletActivity{
+			WalletID:         walletID,
+			TransactionID:    txID,
+			Direction:        domain.ActivityCredit,
+			Amount:           decimal.NewFromInt(500),
+			Currency:         domain.MWK,
+			CounterpartyName: "Alice Banda",
+			TransactionType:  domain.TransactionTypePayment,
+			Status:           status,
+			OccurredAt:       occurredAt,
+			SourceUpdatedAt:  updatedAt,
+		}
+	}
+
+	// Feed rows go with their wallet and transaction
+	txIDs := make([]uuid.UUID, 3)
+	for i := range txIDs {
+		txIDs[i] = testCredit(t, db, userID, walletID, 500, domain.TransactionStatusCompleted, "", now)
+	}
+
+	t.Run("older versions do not overwrite newer ones", func(t *testing.T) {
+		completed := row(txIDs[0], now, now.Add(time.Second), domain.TransactionStatusCompleted)
+		pending := completed
+		pending.Status, pending.SourceUpdatedAt = domain.TransactionStatusPending, now
+		require.NoError(t, repo.Upsert(ctx, []domain.WalletActivity{completed}))
+		require.NoError(t, repo.Upsert(ctx, []domain.WalletActivity{pending}))
+
+		rows, err := repo.FindByWalletAfter(ctx, walletID, nil, 10)
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, domain.TransactionStatusCompleted, rows[0].Status)
+		assert.Equal(t, "Alice Banda", rows[0].CounterpartyName)
+
+		var stored string
+		require.NoError(t, db.Get(&stored, `SELECT counterparty_name FROM customer_schema.wallet_activity WHERE transaction_id = $1`, completed.TransactionID))
+		assert.NotEqual(t, "Alice Banda", stored, "encrypted at rest")
+
+		reversed := completed
+		reversed.Status, reversed.SourceUpdatedAt = domain.TransactionStatusReversed, now.Add(time.Minute)
+		require.NoError(t, repo.Upsert(ctx, []domain.WalletActivity{reversed}))
+		rows, err = repo.FindByWalletAfter(ctx, walletID, nil, 10)
+		require.NoError(t, err)
+		assert.Equal(t, domain.TransactionStatusReversed, rows[0].Status)
+	})
+
+	t.Run("pages newest first", func(t *testing.T) {
+		// Two more, one at the same moment as the first, so the
+		// transaction id breaks the tie.
+		tied := row(txIDs[1], now, now, domain.TransactionStatusCompleted)
+		newest := row(txIDs[2], now.Add(time.Minute), now.Add(time.Minute), domain.TransactionStatusCompleted)
+		require.NoError(t, repo.Upsert(ctx, []domain.WalletActivity{tied, newest}))
+
+		all, total, err := repo.FindByWallet(ctx, walletID, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		require.Len(t, all, 3)
+		assert.Equal(t, newest.TransactionID, all[0].TransactionID)
+		assert.True(t, all[1].TransactionID.String() > all[2].TransactionID.String(), "ties by transaction id, descending")
+
+		first, err := repo.FindByWalletAfter(ctx, walletID, nil, 2)
+		require.NoError(t, err)
+		assert.Equal(t, activityIDs(all[:2]), activityIDs(first))
+		rest, err := repo.FindByWalletAfter(ctx, walletID, first[1].Cursor(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, activityIDs(all[2:]), activityIDs(rest), "the cursor row is not repeated")
+
+		page, total, err := repo.FindByWallet(ctx, walletID, 2, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, activityIDs(all[2:]), activityIDs(page))
+	})
+}
+
+func activityIDs(rows []domain.WalletActivity) []uuid.UUID {
+	ids := make([]uuid.UUID, len(rows))
+	for i, a := range rows {
+		ids[i] = a.TransactionID
+	}
+	return ids
+}
@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/webhook"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepository(db *sqlx.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const webhookEndpointColumns = `
+	id, owner_id, url, description, events, secret, previous_secret, previous_secret_expires_at,
+	active, created_by, created_at, updated_at`
+
+const webhookDeliveryColumns = `
+	id, endpoint_id, event_id, event_type, replay_of, payload, status, attempts, next_attempt_at,
+	response_status, response_body, last_error, duration_ms, delivered_at, created_at, updated_at`
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.webhook_endpoints (`+webhookEndpointColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, e.ID, e.OwnerID, e.URL, e.Description, e.Events, e.Secret, e.PreviousSecret, e.PreviousSecretExpiresAt,
+		e.Active, e.CreatedBy, e.CreatedAt, e.UpdatedAt)
+	return errors.Wrap(err, "failed to create webhook endpoint")
+}
+
+func (r *WebhookRepository) UpdateEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.webhook_endpoints
+		SET url = $2, description = $3, events = $4, secret = $5, previous_secret = $6,
+			previous_secret_expires_at = $7, active = $8, updated_at = $9
+		WHERE id = $1
+	`, e.ID, e.URL, e.Description, e.Events, e.Secret, e.PreviousSecret, e.PreviousSecretExpiresAt, e.Active, e.UpdatedAt)
+	return errors.Wrap(err, "failed to update webhook endpoint")
+}
+
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM admin_schema.webhook_endpoints WHERE id = $1`, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete webhook endpoint")
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, id uuid.UUID) (*domain.WebhookEndpoint, error) {
+	var e domain.WebhookEndpoint
+	err := r.db.GetContext(ctx, &e, `SELECT `+webhookEndpointColumns+` FROM admin_schema.webhook_endpoints WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get webhook endpoint")
+	}
+	return &e, nil
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, owner *uuid.UUID) ([]domain.WebhookEndpoint, error) {
+	endpoints := []domain.WebhookEndpoint{}
+	query := `SELECT ` + webhookEndpointColumns + ` FROM admin_schema.webhook_endpoints`
+	var args []interface{}
+	if owner != nil {
+		query += ` WHERE owner_id = $1`
+		args = append(args, *owner)
+	}
+	query += ` ORDER BY created_at`
+	if err := r.db.SelectContext(ctx, &endpoints, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to list webhook endpoints")
+	}
+	return endpoints, nil
+}
+
+func (r *WebhookRepository) CountEndpoints(ctx context.Context, owner uuid.UUID) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM admin_schema.webhook_endpoints WHERE owner_id = $1`, owner)
+	return n, errors.Wrap(err, "failed to count webhook endpoints")
+}
+
+func (r *WebhookRepository) ListActiveEndpoints(ctx context.Context, owners []uuid.UUID) ([]domain.WebhookEndpoint, error) {
+	endpoints := []domain.WebhookEndpoint{}
+	err := r.db.SelectContext(ctx, &endpoints, `
+		SELECT `+webhookEndpointColumns+` FROM admin_schema.webhook_endpoints
+		WHERE active AND (owner_id IS NULL OR owner_id = ANY($1))
+	`, pq.Array(owners))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list active webhook endpoints")
+	}
+	return endpoints, nil
+}
+
+func (r *WebhookRepository) CreateDeliveries(ctx context.Context, ds []*domain.WebhookDelivery) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+	for _, d := range ds {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO admin_schema.webhook_deliveries (`+webhookDeliveryColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			ON CONFLICT (endpoint_id, event_id) WHERE replay_of IS NULL DO NOTHING
+		`,
+			d.ID, d.EndpointID, d.EventID, d.EventType, d.ReplayOf, d.Payload, d.Status, d.Attempts, d.NextAttemptAt,
+			d.ResponseStatus, d.ResponseBody, d.LastError, d.DurationMS, d.DeliveredAt, d.CreatedAt, d.UpdatedAt,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to queue webhook delivery")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "failed to commit webhook deliveries")
+}
+
+func (r *WebhookRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	deliveries := []domain.WebhookDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, `
+		UPDATE admin_schema.webhook_deliveries
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM admin_schema.webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns,
+		now, now.Add(lease), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_schema.webhook_deliveries SET
+			status = $2, attempts = $3, next_attempt_at = $4, response_status = $5,
+			response_body = $6, last_error = $7, duration_ms = $8, delivered_at = $9, updated_at = $10
+		WHERE id = $1
+	`, d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.ResponseStatus, d.ResponseBody, d.LastError, d.DurationMS, d.DeliveredAt, d.UpdatedAt)
+	return errors.Wrap(err, "failed to update webhook delivery")
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*domain.WebhookDelivery, error) {
+	var d domain.WebhookDelivery
+	err := r.db.GetContext(ctx, &d, `SELECT `+webhookDeliveryColumns+` FROM admin_schema.webhook_deliveries WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get webhook delivery")
+	}
+	return &d, nil
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID uuid.UUID, filter webhook.DeliveryFilter) ([]domain.WebhookDelivery, error) {
+	where := []string{"endpoint_id = $1"}
+	args := []interface{}{endpointID}
+	add := func(clause string, value interface{}) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+	if filter.EventType != "" {
+		add("event_type = $%d", filter.EventType)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+	if filter.OriginalsOnly {
+		where = append(where, "replay_of IS NULL")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM admin_schema.webhook_deliveries WHERE ` + strings.Join(where, " AND ") +
+		fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	deliveries := []domain.WebhookDelivery{}
+	if err := r.db.SelectContext(ctx, &deliveries, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to list webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) ListEventDeliveries(ctx context.Context, eventID uuid.UUID) ([]domain.WebhookDelivery, error) {
+	deliveries := []domain.WebhookDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, `
+		SELECT `+webhookDeliveryColumns+` FROM admin_schema.webhook_deliveries
+		WHERE event_id = $1
+		ORDER BY created_at
+	`, eventID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list event deliveries")
+	}
+	return deliveries, nil
+}
+
+// SummarizeDeliveries sums the deliveries queued for each of owner's
+// endpoints from since on.
+func (r *WebhookRepository) SummarizeDeliveries(ctx context.Context, owner uuid.UUID, since time.Time) ([]domain.WebhookDeliverySummary, error) {
+	summaries := []domain.WebhookDeliverySummary{}
+	err := r.db.SelectContext(ctx, &summaries, `
+		SELECT e.id AS endpoint_id, e.url, e.active,
+			COUNT(d.id) AS total,
+			COUNT(d.id) FILTER (WHERE d.status = 'succeeded') AS succeeded,
+			COUNT(d.id) FILTER (WHERE d.status = 'failed') AS failed,
+			COUNT(d.id) FILTER (WHERE d.status = 'pending') AS pending,
+			COUNT(d.id) FILTER (WHERE d.status = 'pending' AND d.attempts > 0) AS retrying,
+			COALESCE(AVG(d.duration_ms) FILTER (WHERE d.attempts > 0), 0)::BIGINT AS avg_duration_ms,
+			MAX(d.delivered_at) AS last_delivered_at,
+			MAX(d.updated_at) FILTER (WHERE d.last_error <> '') AS last_failed_at,
+			COALESCE((
+				SELECT f.last_error FROM admin_schema.webhook_deliveries f
+				WHERE f.endpoint_id = e.id AND f.created_at >= $2 AND f.last_error <> ''
+				ORDER BY f.updated_at DESC
+				LIMIT 1
+			), '') AS last_error
+		FROM admin_schema.webhook_endpoints e
+		LEFT JOIN admin_schema.webhook_deliveries d ON d.endpoint_id = e.id AND d.created_at >= $2
+		WHERE e.owner_id = $1
+		GROUP BY e.id, e.url, e.active, e.created_at
+		ORDER BY e.created_at
+	`, owner, since)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to summarize webhook deliveries")
+	}
+	return summaries, nil
+}
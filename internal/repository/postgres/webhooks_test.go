@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/webhook"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookRepository_Deliveries(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewWebhookRepository(db)
+	now := time.Date(1990, 3, 2, 9, 0, 0, 0, time.UTC)
+	owner := testUser(t, db, now)
+
+	// Endpoints and their deliveries go with their owner
+	e := testWebhookEndpoint(t, repo, &owner, true)
+	disabled := testWebhookEndpoint(t, repo, &owner, false)
+	platform := testWebhookEndpoint(t, repo, nil, true)
+	t.Cleanup(func() { repo.DeleteEndpoint(ctx, platform.ID) })
+
+	t.Run("active endpoints are the platform's and the owners'", func(t *testing.T) {
+		endpoints, err := repo.ListActiveEndpoints(ctx, []uuid.UUID{owner, uuid.New()})
+		require.NoError(t, err)
+		ids := map[uuid.UUID]bool{}
+		for _, found := range endpoints {
+			ids[found.ID] = true
+		}
+		assert.True(t, ids[e.ID])
+		assert.True(t, ids[platform.ID])
+		assert.False(t, ids[disabled.ID])
+
+		endpoints, err = repo.ListActiveEndpoints(ctx, []uuid.UUID{uuid.New()})
+		require.NoError(t, err)
+		for _, found := range endpoints {
+			assert.NotEqual(t, e.ID, found.ID, "someone else's")
+		}
+	})
+
+	original := newTestDelivery(e, now)
+	require.NoError(t, repo.CreateDeliveries(ctx, []*domain.WebhookDelivery{original}))
+
+	t.Run("originals are queued once per endpoint and event", func(t *testing.T) {
+		again := newTestDelivery(e, now)
+		again.EventID = original.EventID
+		elsewhere := newTestDelivery(platform, now)
+		elsewhere.EventID = original.EventID
+		require.NoError(t, repo.CreateDeliveries(ctx, []*domain.WebhookDelivery{again, elsewhere}))
+
+		got, err := repo.GetDelivery(ctx, again.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got, "skipped")
+		got, err = repo.GetDelivery(ctx, elsewhere.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, got)
+
+		for i := 0; i < 2; i++ {
+			replay := newTestDelivery(e, now)
+			replay.EventID, replay.ReplayOf = original.EventID, &original.ID
+			require.NoError(t, repo.CreateDeliveries(ctx, []*domain.WebhookDelivery{replay}))
+		}
+		deliveries, err := repo.ListEventDeliveries(ctx, original.EventID)
+		require.NoError(t, err)
+		assert.Len(t, deliveries, 4, "replays are always queued")
+	})
+
+	t.Run("claims push the next attempt back", func(t *testing.T) {
+		later := newTestDelivery(e, now.Add(time.Minute))
+		done := newTestDelivery(e, now)
+		done.Status, done.NextAttemptAt = domain.WebhookDeliverySucceeded, nil
+		require.NoError(t, repo.CreateDeliveries(ctx, []*domain.WebhookDelivery{later, done}))
+
+		claimed, err := repo.ClaimDue(ctx, now, 20*time.Second, 100)
+		require.NoError(t, err)
+		ids := deliveryIDs(claimed)
+		assert.Contains(t, ids, original.ID)
+		assert.NotContains(t, ids, later.ID, "not due yet")
+		assert.NotContains(t, ids, done.ID)
+
+		claimed, err = repo.ClaimDue(ctx, now.Add(10*time.Second), 20*time.Second, 100)
+		require.NoError(t, err)
+		assert.NotContains(t, deliveryIDs(claimed), original.ID, "leased")
+
+		got, err := repo.GetDelivery(ctx, original.ID)
+		require.NoError(t, err)
+		assert.True(t, got.NextAttemptAt.Equal(now.Add(20*time.Second)))
+
+		got.Status, got.Attempts, got.NextAttemptAt = domain.WebhookDeliveryFailed, 3, nil
+		require.NoError(t, repo.UpdateDelivery(ctx, got))
+		claimed, err = repo.ClaimDue(ctx, now.Add(time.Hour), 20*time.Second, 100)
+		require.NoError(t, err)
+		assert.NotContains(t, deliveryIDs(claimed), original.ID, "finished")
+	})
+
+	t.Run("listing filters", func(t *testing.T) {
+		from, to := now, now.Add(time.Minute)
+		deliveries, err := repo.ListDeliveries(ctx, e.ID, webhook.DeliveryFilter{From: &from, To: &to, OriginalsOnly: true, Limit: 20})
+		require.NoError(t, err)
+		ids := deliveryIDs(deliveries)
+		assert.Contains(t, ids, original.ID, "from is inclusive")
+		assert.Len(t, ids, 2, "the original and the succeeded one; to is exclusive and replays are left out")
+
+		deliveries, err = repo.ListDeliveries(ctx, e.ID, webhook.DeliveryFilter{Status: domain.WebhookDeliveryFailed, Limit: 20})
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{original.ID}, deliveryIDs(deliveries))
+
+		deliveries, err = repo.ListDeliveries(ctx, e.ID, webhook.DeliveryFilter{Limit: 2, Offset: 4})
+		require.NoError(t, err)
+		assert.Len(t, deliveries, 1, "five in all")
+	})
+}
+
+func testWebhookEndpoint(t *testing.T, repo *WebhookRepository, owner *uuid.UUID, active bool) *domain.WebhookEndpoint {
+	t.Helper()
+	e := &domain.WebhookEndpoint{
+		ID: uuid.New(), OwnerID: owner, URL: "https://example.com/hook", Events: []string{},
+		Secret: "whsec_test", Active: active, CreatedBy: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+	require.NoError(t, repo.CreateEndpoint(context.Background(), e))
+	return e
+}
+
+// newTestDelivery is a delivery to e queued, and due, at at.
+func newTestDelivery(e *domain.WebhookEndpoint, at time.Time) *domain.WebhookDelivery {
+	return &domain.WebhookDelivery{
+		ID:            uuid.New(),
+		EndpointID:    e.ID,
+		EventID:       uuid.New(),
+		EventType:     domain.WebhookTransactionCompleted,
+		Payload:       []byte(`{}`),
+		Status:        domain.WebhookDeliveryPending,
+		NextAttemptAt: &at,
+		CreatedAt:     at,
+		UpdatedAt:     at,
+	}
+}
+
+func deliveryIDs(items []domain.WebhookDelivery) []uuid.UUID {
+	ids := make([]uuid.UUID, len(items))
+	for i, d := range items {
+		ids[i] = d.ID
+	}
+	return ids
+}
@@ -0,0 +1,158 @@
+package risk
+
+import (
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+
+	"github.com/shopspring/decimal"
+)
+
+// HighValuePerHour is how many payments above the high-value threshold a
+// sender may make in an hour.
+const HighValuePerHour = 3
+
+// kycAmountLimits is, per KYC level, the amount above which a payment is
+// risky for the sender.
+var kycAmountLimits = map[int]int64{0: 1000, 1: 10000, 2: 100000}
+
+// newAccountDays is the account age under which high-value payments score
+// a second time.
+const newAccountDays = 7
+
+// Reasons a payment is blocked.
+const (
+	BlockVelocity          = "velocity"
+	BlockHighValueVelocity = "high_value_velocity"
+	BlockRiskScore         = "risk_score"
+)
+
+// RulesFromConfig returns the rules cfg makes live.
+func RulesFromConfig(cfg config.RiskConfig) domain.RiskRules {
+	limits := make(map[int]int64, len(kycAmountLimits))
+	for level, limit := range kycAmountLimits {
+		limits[level] = limit
+	}
+	return domain.RiskRules{
+		KYCAmountLimits:        limits,
+		HighValueThreshold:     cfg.HighValueThreshold,
+		NewAccountDays:         newAccountDays,
+		SuspiciousLocation:     cfg.SuspiciousLocationAlert,
+		MaxVelocityPerHour:     cfg.MaxVelocityPerHour,
+		HighValuePerHour:       HighValuePerHour,
+		AdminApprovalThreshold: cfg.AdminApprovalThreshold,
+		AlertScore:             int(RiskScoreHigh),
+		BlockScore:             int(RiskScoreCritical),
+	}
+}
+
+// Rules returns the live rules.
+func (re *RiskEngine) Rules() domain.RiskRules {
+	re.mu.RLock()
+	defer re.mu.RUnlock()
+	return RulesFromConfig(re.config)
+}
+
+// Inputs is what a payment is scored on.
+type Inputs struct {
+	Amount         decimal.Decimal
+	KYCLevel       int
+	IsNewDevice    bool
+	Location       string
+	AccountAgeDays int
+	// Recent are the amounts of the sender's payments in the hour before,
+	// for the velocity checks.
+	Recent []decimal.Decimal
+}
+
+// Score is the risk score of a payment under rules.
+func Score(rules domain.RiskRules, in Inputs) RiskScore {
+	score := RiskScoreLow
+
+	// Rule 1: High Amount vs KYC
+	limit, ok := rules.KYCAmountLimits[in.KYCLevel]
+	if !ok {
+		limit = rules.KYCAmountLimits[0]
+	}
+	if in.Amount.GreaterThan(decimal.NewFromInt(limit)) {
+		score += 40
+	}
+
+	// Rule 2: New Device
+	if in.IsNewDevice {
+		score += 60
+	}
+
+	highValue := in.Amount.GreaterThan(decimal.NewFromInt(rules.HighValueThreshold))
+	if highValue {
+		score += 40
+	}
+
+	// Rule 4: Suspicious Location
+	if in.Location == rules.SuspiciousLocation {
+		score += 50
+	}
+
+	if in.AccountAgeDays >= 0 && in.AccountAgeDays < rules.NewAccountDays && highValue {
+		score += 40
+	}
+
+	if score > RiskScoreCritical {
+		score = RiskScoreCritical
+	}
+	return score
+}
+
+// Decision is what the rules do with a payment. A blocked payment raises
+// no alert and is not held for review.
+type Decision struct {
+	Score RiskScore
+	// BlockedBy is the first check that blocks the payment, if any.
+	BlockedBy string
+	Alert     bool
+	Review    bool
+}
+
+func (d Decision) Blocked() bool { return d.BlockedBy != "" }
+
+// String names the decision: blocked, review, alert or allowed.
+func (d Decision) String() string {
+	switch {
+	case d.Blocked():
+		return "blocked"
+	case d.Review:
+		return "review"
+	case d.Alert:
+		return "alert"
+	}
+	return "allowed"
+}
+
+// Decide scores a payment under rules and applies the checks made when it
+// is initiated, in the same order: hourly velocity, high-value velocity,
+// then the score.
+func Decide(rules domain.RiskRules, in Inputs) Decision {
+	d := Decision{Score: Score(rules, in)}
+	threshold := decimal.NewFromInt(rules.HighValueThreshold)
+	switch {
+	case rules.MaxVelocityPerHour > 0 && len(in.Recent) >= rules.MaxVelocityPerHour:
+		d.BlockedBy = BlockVelocity
+	case rules.HighValuePerHour > 0 && in.Amount.GreaterThan(threshold) && countAbove(in.Recent, threshold) >= rules.HighValuePerHour:
+		d.BlockedBy = BlockHighValueVelocity
+	case int(d.Score) >= rules.BlockScore:
+		d.BlockedBy = BlockRiskScore
+	default:
+		d.Alert = int(d.Score) >= rules.AlertScore
+		d.Review = rules.AdminApprovalThreshold > 0 && in.Amount.GreaterThanOrEqual(decimal.NewFromInt(rules.AdminApprovalThreshold))
+	}
+	return d
+}
+
+func countAbove(amounts []decimal.Decimal, threshold decimal.Decimal) int {
+	n := 0
+	for _, a := range amounts {
+		if a.GreaterThan(threshold) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,72 @@
+package risk
+
+import (
+	"testing"
+
+	"kyd/pkg/config"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRules() config.RiskConfig {
+	return config.RiskConfig{
+		HighValueThreshold:      100000,
+		MaxVelocityPerHour:      10,
+		SuspiciousLocationAlert: "North Korea",
+		AdminApprovalThreshold:  500000,
+	}
+}
+
+func TestEvaluateRiskUsesLiveRules(t *testing.T) {
+	re := NewRiskEngine(testRules())
+	amount := decimal.NewFromInt(150000)
+
+	// Above the KYC 2 limit and high value
+	assert.Equal(t, RiskScore(80), re.EvaluateRisk(amount, 2, false, "MW", 30))
+	// Above the limit of an unlisted level, which is level 0's
+	assert.Equal(t, RiskScore(80), re.EvaluateRisk(amount, 3, false, "MW", 30))
+	// A new account scores high value twice
+	assert.Equal(t, RiskScoreCritical, re.EvaluateRisk(amount, 2, false, "MW", 3))
+	assert.Equal(t, RiskScore(50), re.EvaluateRisk(decimal.NewFromInt(10), 2, false, "North Korea", 30))
+
+	re.SetHighValueThreshold(200000)
+	assert.Equal(t, RiskScore(40), re.EvaluateRisk(amount, 2, false, "MW", 3))
+}
+
+func TestDecide(t *testing.T) {
+	rules := RulesFromConfig(testRules())
+	small, big := decimal.NewFromInt(500), decimal.NewFromInt(150000)
+
+	d := Decide(rules, Inputs{Amount: small, KYCLevel: 2, AccountAgeDays: 30})
+	assert.Equal(t, "allowed", d.String())
+
+	d = Decide(rules, Inputs{Amount: big, KYCLevel: 2, AccountAgeDays: 30})
+	assert.Equal(t, "alert", d.String())
+	assert.Equal(t, RiskScore(80), d.Score)
+
+	d = Decide(rules, Inputs{Amount: decimal.NewFromInt(600000), KYCLevel: 2, AccountAgeDays: 30})
+	assert.True(t, d.Review)
+	assert.True(t, d.Alert)
+	assert.Equal(t, "review", d.String())
+
+	d = Decide(rules, Inputs{Amount: big, KYCLevel: 2, AccountAgeDays: 1})
+	assert.Equal(t, BlockRiskScore, d.BlockedBy)
+	assert.False(t, d.Alert)
+
+	recent := make([]decimal.Decimal, 10)
+	for i := range recent {
+		recent[i] = small
+	}
+	d = Decide(rules, Inputs{Amount: small, AccountAgeDays: 30, Recent: recent})
+	assert.Equal(t, BlockVelocity, d.BlockedBy)
+
+	d = Decide(rules, Inputs{Amount: big, KYCLevel: 2, AccountAgeDays: 30, Recent: []decimal.Decimal{big, big, big}})
+	assert.Equal(t, BlockHighValueVelocity, d.BlockedBy)
+	d = Decide(rules, Inputs{Amount: small, KYCLevel: 2, AccountAgeDays: 30, Recent: []decimal.Decimal{big, big, big}})
+	assert.False(t, d.Blocked(), "only high-value payments count against the high-value limit")
+
+	rules.MaxVelocityPerHour = 0
+	d = Decide(rules, Inputs{Amount: small, AccountAgeDays: 30, Recent: recent})
+	assert.False(t, d.Blocked(), "0 turns the limit off")
+}
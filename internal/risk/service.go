@@ -208,45 +208,13 @@ func (re *RiskEngine) RequiresAdminApproval(amount decimal.Decimal) bool {
 
 // EvaluateRisk calculates the risk score for a transaction
 func (re *RiskEngine) EvaluateRisk(amount decimal.Decimal, kycLevel int, isNewDevice bool, location string, accountAgeDays int) RiskScore {
-	score := RiskScoreLow
-
-	// Rule 1: High Amount vs KYC
-	limit := decimal.NewFromInt(1000)
-	if kycLevel == 1 {
-		limit = decimal.NewFromInt(10000)
-	} else if kycLevel == 2 {
-		limit = decimal.NewFromInt(100000)
-	}
-
-	if amount.GreaterThan(limit) {
-		score += 40
-	}
-
-	// Rule 2: New Device
-	if isNewDevice {
-		score += 60
-	}
-
-	if amount.GreaterThan(decimal.NewFromInt(re.config.HighValueThreshold)) {
-		score += 40
-	}
-
-	// Rule 4: Suspicious Location
-	if location == re.config.SuspiciousLocationAlert {
-		score += 50
-	}
-
-	if accountAgeDays >= 0 && accountAgeDays < 7 {
-		if amount.GreaterThan(decimal.NewFromInt(re.config.HighValueThreshold)) {
-			score += 40
-		}
-	}
-
-	if score > RiskScoreCritical {
-		score = RiskScoreCritical
-	}
-
-	return score
+	return Score(re.Rules(), Inputs{
+		Amount:         amount,
+		KYCLevel:       kycLevel,
+		IsNewDevice:    isNewDevice,
+		Location:       location,
+		AccountAgeDays: accountAgeDays,
+	})
 }
 
 func (re *RiskEngine) GetStatus() RiskStatus {
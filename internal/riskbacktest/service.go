@@ -0,0 +1,414 @@
+// Package riskbacktest replays past payments through candidate risk rules
+// before they go live. Each backtest scores every payment of a window under
+// the rules live when it was requested and under the candidate, applying
+// the same velocity, score and approval checks as payment initiation, and
+// reports how many payments each would have blocked, alerted on or held.
+// Production data is only read.
+package riskbacktest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/risk"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrBacktestNotFound = errors.New("risk backtest not found")
+	ErrInvalidBacktest  = errors.New("invalid risk backtest")
+)
+
+// errStopped ends a backtest cut short by Stop, which is left running for
+// another worker to take over.
+var errStopped = errors.New("risk backtest worker stopped")
+
+const (
+	// maxWindow is the longest window a backtest may replay.
+	maxWindow = 92 * 24 * time.Hour
+	// batchSize is how many payments are read at a time.
+	batchSize = 1000
+	// maxChanges is how many changed payments a backtest lists.
+	maxChanges = 100
+	// lease is how long a running backtest may go without progress before
+	// another worker takes it over.
+	lease        = 5 * time.Minute
+	pollInterval = time.Minute
+	// velocityWindow is how far back the velocity checks look.
+	velocityWindow = time.Hour
+)
+
+// Repository stores backtests and reads the payments they replay.
+type Repository interface {
+	Create(ctx context.Context, b *domain.RiskBacktest) error
+	Get(ctx context.Context, id uuid.UUID) (*domain.RiskBacktest, error)
+	List(ctx context.Context, limit, offset int) ([]domain.RiskBacktest, int, error)
+	// Claim marks the oldest queued backtest, or one running whose worker
+	// has made no progress since staleBefore, as running and returns it.
+	Claim(ctx context.Context, now, staleBefore time.Time) (*domain.RiskBacktest, error)
+	Update(ctx context.Context, b *domain.RiskBacktest) error
+	// Payments returns up to limit payments created in [from, to) after
+	// the (afterTime, afterID) cursor, oldest first.
+	Payments(ctx context.Context, from, to, afterTime time.Time, afterID uuid.UUID, limit int) ([]domain.RiskBacktestPayment, error)
+}
+
+// Rules gives the live risk rules. Satisfied by *risk.RiskEngine.
+type Rules interface {
+	Rules() domain.RiskRules
+}
+
+type Service struct {
+	repo   Repository
+	rules  Rules
+	logger logger.Logger
+	now    func() time.Time
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+func NewService(repo Repository, rules Rules, log logger.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		rules:  rules,
+		logger: log,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Request asks for a backtest. Rules holds the candidate's changes to the
+// live rules; fields left out keep their live value.
+type Request struct {
+	From  time.Time       `json:"from"`
+	To    time.Time       `json:"to"`
+	Rules json.RawMessage `json:"rules"`
+	Note  string          `json:"note"`
+}
+
+// LiveRules returns the rules backtests compare candidates with.
+func (s *Service) LiveRules() domain.RiskRules {
+	return s.rules.Rules()
+}
+
+// Create queues a backtest of the candidate rules over a past window.
+func (s *Service) Create(ctx context.Context, req Request, requestedBy uuid.UUID) (*domain.RiskBacktest, error) {
+	now := s.now().UTC()
+	from, to := req.From.UTC(), req.To.UTC()
+	switch {
+	case from.IsZero() || to.IsZero():
+		return nil, fmt.Errorf("%w: from and to are required", ErrInvalidBacktest)
+	case !from.Before(to):
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidBacktest)
+	case to.After(now):
+		return nil, fmt.Errorf("%w: the window must have ended", ErrInvalidBacktest)
+	case to.Sub(from) > maxWindow:
+		return nil, fmt.Errorf("%w: the window may span at most %d days", ErrInvalidBacktest, int(maxWindow.Hours()/24))
+	}
+	baseline := s.rules.Rules()
+	candidate := s.rules.Rules()
+	if len(req.Rules) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(req.Rules))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&candidate); err != nil {
+			return nil, fmt.Errorf("%w: rules: %v", ErrInvalidBacktest, err)
+		}
+	}
+	if err := validateRules(candidate); err != nil {
+		return nil, err
+	}
+	b := &domain.RiskBacktest{
+		ID:          uuid.New(),
+		From:        from,
+		To:          to,
+		Baseline:    baseline,
+		Candidate:   candidate,
+		Note:        req.Note,
+		Status:      domain.RiskBacktestQueued,
+		RequestedBy: requestedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.Create(ctx, b); err != nil {
+		return nil, err
+	}
+	s.kick()
+	return b, nil
+}
+
+func validateRules(r domain.RiskRules) error {
+	if _, ok := r.KYCAmountLimits[0]; !ok {
+		return fmt.Errorf("%w: kyc_amount_limits must include level 0", ErrInvalidBacktest)
+	}
+	for level, limit := range r.KYCAmountLimits {
+		if level < 0 || limit < 0 {
+			return fmt.Errorf("%w: kyc_amount_limits must be non-negative", ErrInvalidBacktest)
+		}
+	}
+	switch {
+	case r.HighValueThreshold < 0, r.NewAccountDays < 0, r.MaxVelocityPerHour < 0, r.HighValuePerHour < 0, r.AdminApprovalThreshold < 0:
+		return fmt.Errorf("%w: thresholds and limits must be non-negative", ErrInvalidBacktest)
+	case r.AlertScore < 0 || r.AlertScore > int(risk.RiskScoreCritical), r.BlockScore < 1 || r.BlockScore > int(risk.RiskScoreCritical):
+		return fmt.Errorf("%w: alert_score must be 0-100 and block_score 1-100", ErrInvalidBacktest)
+	}
+	return nil
+}
+
+// Get returns a backtest.
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.RiskBacktest, error) {
+	b, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBacktestNotFound
+	}
+	return b, nil
+}
+
+// List returns backtests, newest first.
+func (s *Service) List(ctx context.Context, limit, offset int) ([]domain.RiskBacktest, int, error) {
+	return s.repo.List(ctx, limit, offset)
+}
+
+// Start runs queued backtests, one at a time, until Stop.
+func (s *Service) Start() {
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			for s.RunNext(context.Background()) {
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Stop ends the worker. A backtest it was running is taken over once its
+// lease runs out.
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.done.Wait()
+}
+
+func (s *Service) kick() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunNext claims and runs one backtest, and reports whether there was one.
+func (s *Service) RunNext(ctx context.Context) bool {
+	now := s.now().UTC()
+	b, err := s.repo.Claim(ctx, now, now.Add(-lease))
+	if err != nil {
+		s.logger.Error("Failed to claim risk backtest", map[string]interface{}{"error": err.Error()})
+		return false
+	}
+	if b == nil {
+		return false
+	}
+	results, err := s.run(ctx, b)
+	if errors.Is(err, errStopped) {
+		return false
+	}
+	now = s.now().UTC()
+	b.UpdatedAt = now
+	b.CompletedAt = &now
+	if err != nil {
+		msg := err.Error()
+		b.Status = domain.RiskBacktestFailed
+		b.Error = &msg
+		s.logger.Error("Risk backtest failed", map[string]interface{}{"backtest_id": b.ID, "error": msg})
+	} else {
+		b.Status = domain.RiskBacktestCompleted
+		b.Results = results
+		s.logger.Info("Risk backtest completed", map[string]interface{}{
+			"backtest_id":   b.ID,
+			"transactions":  results.Transactions,
+			"blocked_delta": results.BlockedDelta,
+			"alerts_delta":  results.AlertsDelta,
+		})
+	}
+	if err := s.repo.Update(ctx, b); err != nil {
+		s.logger.Error("Failed to record risk backtest", map[string]interface{}{"backtest_id": b.ID, "error": err.Error()})
+	}
+	return true
+}
+
+// run replays b's window. Payments from the hour before it are read too,
+// so that the velocity checks of its first payments see them.
+func (s *Service) run(ctx context.Context, b *domain.RiskBacktest) (*domain.RiskBacktestResults, error) {
+	res := &domain.RiskBacktestResults{
+		Baseline:  newOutcome(),
+		Candidate: newOutcome(),
+		Changes:   []domain.RiskBacktestChange{},
+	}
+	var baselineTotal, candidateTotal int
+	recent := map[uuid.UUID][]domain.RiskBacktestPayment{}
+	var afterTime time.Time
+	afterID := uuid.Nil
+	b.Processed = 0
+	for {
+		batch, err := s.repo.Payments(ctx, b.From.Add(-velocityWindow), b.To, afterTime, afterID, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range batch {
+			prior := trim(recent[p.SenderID], p.CreatedAt)
+			if !p.CreatedAt.Before(b.From) {
+				in := inputs(p, prior)
+				base := risk.Decide(b.Baseline, in)
+				cand := risk.Decide(b.Candidate, in)
+				res.Transactions++
+				baselineTotal += int(base.Score)
+				candidateTotal += int(cand.Score)
+				tally(&res.Baseline, p, base)
+				tally(&res.Candidate, p, cand)
+				compare(res, p, base, cand)
+			}
+			// Failed and cancelled payments do not count towards velocity.
+			if p.Status != domain.TransactionStatusFailed && p.Status != domain.TransactionStatusCancelled {
+				prior = append(prior, p)
+			}
+			recent[p.SenderID] = prior
+		}
+		if len(batch) > 0 {
+			last := batch[len(batch)-1]
+			afterTime, afterID = last.CreatedAt, last.ID
+		}
+		// Recording progress also renews the lease.
+		b.Processed = res.Transactions
+		b.UpdatedAt = s.now().UTC()
+		if err := s.repo.Update(ctx, b); err != nil {
+			return nil, err
+		}
+		if len(batch) < batchSize {
+			break
+		}
+		select {
+		case <-s.stop:
+			return nil, errStopped
+		default:
+		}
+		pruneIdle(recent, afterTime)
+	}
+	if res.Transactions > 0 {
+		res.Baseline.AverageScore = average(baselineTotal, res.Transactions)
+		res.Candidate.AverageScore = average(candidateTotal, res.Transactions)
+	}
+	res.BlockedDelta = res.Candidate.Blocked - res.Baseline.Blocked
+	res.AlertsDelta = res.Candidate.Alerts - res.Baseline.Alerts
+	res.ReviewsDelta = res.Candidate.Reviews - res.Baseline.Reviews
+	return res, nil
+}
+
+// inputs is what p is scored on. Location and device are not kept with
+// payments, and the sender's KYC level is today's.
+func inputs(p domain.RiskBacktestPayment, prior []domain.RiskBacktestPayment) risk.Inputs {
+	age := int(p.CreatedAt.Sub(p.SenderCreatedAt).Hours() / 24)
+	if age < 0 {
+		age = 0
+	}
+	amounts := make([]decimal.Decimal, len(prior))
+	for i, q := range prior {
+		amounts[i] = q.Amount
+	}
+	return risk.Inputs{Amount: p.Amount, KYCLevel: p.KYCLevel, AccountAgeDays: age, Recent: amounts}
+}
+
+// trim drops the payments made more than an hour before at.
+func trim(ps []domain.RiskBacktestPayment, at time.Time) []domain.RiskBacktestPayment {
+	i := 0
+	for i < len(ps) && !ps[i].CreatedAt.After(at.Add(-velocityWindow)) {
+		i++
+	}
+	return ps[i:]
+}
+
+// pruneIdle forgets senders with nothing in the hour before at.
+func pruneIdle(recent map[uuid.UUID][]domain.RiskBacktestPayment, at time.Time) {
+	for id, ps := range recent {
+		if len(trim(ps, at)) == 0 {
+			delete(recent, id)
+		}
+	}
+}
+
+func newOutcome() domain.RiskBacktestOutcome {
+	return domain.RiskBacktestOutcome{
+		BlockedByReason: map[string]int{},
+		BlockedAmount:   map[domain.Currency]decimal.Decimal{},
+	}
+}
+
+func tally(o *domain.RiskBacktestOutcome, p domain.RiskBacktestPayment, d risk.Decision) {
+	if d.Blocked() {
+		o.Blocked++
+		o.BlockedByReason[d.BlockedBy]++
+		o.BlockedAmount[p.Currency] = o.BlockedAmount[p.Currency].Add(p.Amount)
+		return
+	}
+	if d.Alert {
+		o.Alerts++
+	}
+	if d.Review {
+		o.Reviews++
+	}
+}
+
+func compare(res *domain.RiskBacktestResults, p domain.RiskBacktestPayment, base, cand risk.Decision) {
+	if base.Score != cand.Score {
+		res.ScoreChanged++
+	}
+	switch {
+	case cand.Blocked() && !base.Blocked():
+		res.NewlyBlocked++
+	case base.Blocked() && !cand.Blocked():
+		res.Unblocked++
+	}
+	switch {
+	case cand.Alert && !base.Alert:
+		res.NewAlerts++
+	case base.Alert && !cand.Alert:
+		res.ClearedAlerts++
+	}
+	if base.String() != cand.String() && len(res.Changes) < maxChanges {
+		res.Changes = append(res.Changes, domain.RiskBacktestChange{
+			TransactionID:     p.ID,
+			Reference:         p.Reference,
+			SenderID:          p.SenderID,
+			Amount:            p.Amount,
+			Currency:          p.Currency,
+			CreatedAt:         p.CreatedAt,
+			BaselineScore:     int(base.Score),
+			CandidateScore:    int(cand.Score),
+			BaselineDecision:  base.String(),
+			CandidateDecision: cand.String(),
+		})
+	}
+}
+
+func average(total, n int) float64 {
+	return float64(total*100/n) / 100
+}
@@ -0,0 +1,304 @@
+package riskbacktest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/risk"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, b *domain.RiskBacktest) error {
+	return m.Called(ctx, b).Error(0)
+}
+
+func (m *MockRepository) Get(ctx context.Context, id uuid.UUID) (*domain.RiskBacktest, error) {
+	args := m.Called(ctx, id)
+	b, _ := args.Get(0).(*domain.RiskBacktest)
+	return b, args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context, limit, offset int) ([]domain.RiskBacktest, int, error) {
+	args := m.Called(ctx, limit, offset)
+	backtests, _ := args.Get(0).([]domain.RiskBacktest)
+	return backtests, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) Claim(ctx context.Context, now, staleBefore time.Time) (*domain.RiskBacktest, error) {
+	args := m.Called(ctx, now, staleBefore)
+	b, _ := args.Get(0).(*domain.RiskBacktest)
+	return b, args.Error(1)
+}
+
+func (m *MockRepository) Update(ctx context.Context, b *domain.RiskBacktest) error {
+	return m.Called(ctx, b).Error(0)
+}
+
+func (m *MockRepository) Payments(ctx context.Context, from, to, afterTime time.Time, afterID uuid.UUID, limit int) ([]domain.RiskBacktestPayment, error) {
+	args := m.Called(ctx, from, to, afterTime, afterID, limit)
+	payments, _ := args.Get(0).([]domain.RiskBacktestPayment)
+	return payments, args.Error(1)
+}
+
+var (
+	ctx = context.Background()
+	// day is the window backtests replay.
+	day = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *MockRepository) {
+	engine := risk.NewRiskEngine(config.RiskConfig{
+		HighValueThreshold:      100000,
+		MaxVelocityPerHour:      10,
+		SuspiciousLocationAlert: "North Korea",
+		AdminApprovalThreshold:  500000,
+	})
+	repo := new(MockRepository)
+	s := NewService(repo, engine, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo
+}
+
+// payment is one by sender at the given offset into day.
+func payment(sender uuid.UUID, kyc int, amount int64, at time.Duration, status domain.TransactionStatus) domain.RiskBacktestPayment {
+	return domain.RiskBacktestPayment{
+		ID:              uuid.New(),
+		Reference:       "KYD-" + at.String(),
+		SenderID:        sender,
+		Amount:          decimal.NewFromInt(amount),
+		Currency:        domain.MWK,
+		Status:          status,
+		CreatedAt:       day.Add(at),
+		KYCLevel:        kyc,
+		SenderCreatedAt: day.AddDate(-1, 0, 0),
+	}
+}
+
+// claimed creates a backtest of rules over day and has the next Claim
+// return it.
+func claimed(t *testing.T, s *Service, repo *MockRepository, now time.Time, rules string) *domain.RiskBacktest {
+	t.Helper()
+	repo.On("Create", ctx, mock.Anything).Return(nil).Once()
+	b, err := s.Create(ctx, Request{From: day, To: day.AddDate(0, 0, 1), Rules: json.RawMessage(rules)}, uuid.New())
+	require.NoError(t, err)
+	assert.Equal(t, domain.RiskBacktestQueued, b.Status)
+	b.Status, b.StartedAt = domain.RiskBacktestRunning, &now
+	repo.On("Claim", ctx, now, now.Add(-lease)).Return(b, nil).Once()
+	return b
+}
+
+// replays has the backtest read payments from the hour before day on,
+// starting after the cursor.
+func replays(repo *MockRepository, afterTime time.Time, afterID uuid.UUID, payments []domain.RiskBacktestPayment) {
+	repo.On("Payments", ctx, day.Add(-time.Hour), day.AddDate(0, 0, 1), afterTime, afterID, batchSize).Return(payments, nil).Once()
+}
+
+// run runs the claimed backtest and returns it as finally recorded.
+func run(t *testing.T, s *Service, repo *MockRepository) domain.RiskBacktest {
+	t.Helper()
+	var done domain.RiskBacktest
+	repo.On("Update", ctx, mock.MatchedBy(func(b *domain.RiskBacktest) bool {
+		return b.Status != domain.RiskBacktestRunning
+	})).Run(func(args mock.Arguments) { done = *args.Get(1).(*domain.RiskBacktest) }).Return(nil).Once()
+	repo.On("Update", ctx, mock.Anything).Return(nil).Maybe()
+	require.True(t, s.RunNext(ctx))
+	repo.AssertExpectations(t)
+	return done
+}
+
+func TestCreateValidates(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, repo := newService(&now)
+	to := day.AddDate(0, 0, 1)
+	bad := []Request{
+		{To: to},
+		{From: to, To: day},
+		{From: day, To: now.Add(time.Hour)},
+		{From: day.AddDate(-1, 0, 0), To: to},
+		{From: day, To: to, Rules: json.RawMessage(`{"block_score": 0}`)},
+		{From: day, To: to, Rules: json.RawMessage(`{"high_value_treshold": 5}`)},
+		{From: day, To: to, Rules: json.RawMessage(`{"max_velocity_per_hour": -1}`)},
+	}
+	for _, req := range bad {
+		_, err := s.Create(ctx, req, uuid.New())
+		assert.ErrorIs(t, err, ErrInvalidBacktest, string(req.Rules))
+	}
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	admin := uuid.New()
+	repo.On("Create", ctx, mock.MatchedBy(func(b *domain.RiskBacktest) bool {
+		return b.Status == domain.RiskBacktestQueued && b.RequestedBy == admin && b.From.Equal(day) && b.CreatedAt.Equal(now)
+	})).Return(nil).Once()
+	b, err := s.Create(ctx, Request{From: day, To: to, Rules: json.RawMessage(`{"kyc_amount_limits": {"2": 50000}}`)}, admin)
+	require.NoError(t, err)
+	// Changes are made to the live rules
+	assert.Equal(t, int64(50000), b.Candidate.KYCAmountLimits[2])
+	assert.Equal(t, int64(10000), b.Candidate.KYCAmountLimits[1])
+	assert.Equal(t, int64(100000), b.Baseline.KYCAmountLimits[2])
+	assert.Equal(t, b.Baseline.HighValueThreshold, b.Candidate.HighValueThreshold)
+
+	missing := uuid.New()
+	repo.On("Get", ctx, missing).Return(nil, nil).Once()
+	_, err = s.Get(ctx, missing)
+	assert.ErrorIs(t, err, ErrBacktestNotFound)
+	repo.AssertExpectations(t)
+}
+
+func TestBacktestComparesCandidateWithLiveRules(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, repo := newService(&now)
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	// Lower high-value threshold and stricter block score
+	claimed(t, s, repo, now, `{"high_value_threshold": 15000, "block_score": 80}`)
+	replays(repo, time.Time{}, uuid.Nil, []domain.RiskBacktestPayment{
+		// Scores 80 live: above the KYC 2 limit and high value
+		payment(alice, 2, 150000, 9*time.Hour, domain.TransactionStatusCompleted),
+		// Scores 40 live: above the KYC 1 limit only
+		payment(bob, 1, 20000, 10*time.Hour, domain.TransactionStatusCompleted),
+		// Held for approval
+		payment(carol, 3, 600000, 11*time.Hour, domain.TransactionStatusCompleted),
+	})
+
+	b := run(t, s, repo)
+	require.Equal(t, domain.RiskBacktestCompleted, b.Status, "error: %v", b.Error)
+	assert.True(t, b.CompletedAt.Equal(now))
+	res := b.Results
+	require.NotNil(t, res)
+	assert.Equal(t, 3, res.Transactions)
+	assert.Equal(t, 3, b.Processed)
+
+	assert.Equal(t, 0, res.Baseline.Blocked)
+	assert.Equal(t, 2, res.Baseline.Alerts)
+	assert.Equal(t, 1, res.Baseline.Reviews)
+
+	assert.Equal(t, 3, res.Candidate.Blocked)
+	assert.Equal(t, 3, res.Candidate.BlockedByReason[risk.BlockRiskScore])
+	assert.True(t, decimal.NewFromInt(770000).Equal(res.Candidate.BlockedAmount[domain.MWK]))
+	assert.Equal(t, 0, res.Candidate.Alerts)
+	assert.Equal(t, 0, res.Candidate.Reviews)
+
+	assert.Equal(t, 3, res.BlockedDelta)
+	assert.Equal(t, -2, res.AlertsDelta)
+	assert.Equal(t, -1, res.ReviewsDelta)
+	assert.Equal(t, 3, res.NewlyBlocked)
+	assert.Equal(t, 2, res.ClearedAlerts)
+	assert.Equal(t, 1, res.ScoreChanged)
+	assert.Equal(t, 66.66, res.Baseline.AverageScore)
+
+	require.Len(t, res.Changes, 3)
+	assert.Equal(t, "alert", res.Changes[0].BaselineDecision)
+	assert.Equal(t, "blocked", res.Changes[0].CandidateDecision)
+	assert.Equal(t, 40, res.Changes[1].BaselineScore)
+	assert.Equal(t, 80, res.Changes[1].CandidateScore)
+	assert.Equal(t, "review", res.Changes[2].BaselineDecision)
+}
+
+func TestBacktestVelocity(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, repo := newService(&now)
+	sender := uuid.New()
+	claimed(t, s, repo, now, `{"max_velocity_per_hour": 5}`)
+	// Payments in the hour before the window count towards the first ones
+	// in it, failed ones do not count at all.
+	var payments []domain.RiskBacktestPayment
+	for i := 0; i < 4; i++ {
+		payments = append(payments, payment(sender, 2, 100, -30*time.Minute+time.Duration(i)*time.Minute, domain.TransactionStatusCompleted))
+	}
+	payments = append(payments,
+		payment(sender, 2, 100, -20*time.Minute, domain.TransactionStatusFailed),
+		payment(sender, 2, 100, 10*time.Minute, domain.TransactionStatusCompleted),
+		payment(sender, 2, 100, 11*time.Minute, domain.TransactionStatusCompleted),
+		// More than an hour after the earlier ones, nothing counts
+		payment(sender, 2, 100, 2*time.Hour, domain.TransactionStatusCompleted),
+	)
+	replays(repo, time.Time{}, uuid.Nil, payments)
+
+	b := run(t, s, repo)
+	res := b.Results
+	require.NotNil(t, res)
+	assert.Equal(t, 3, res.Transactions, "the hour before is not replayed")
+	assert.Equal(t, 0, res.Baseline.Blocked)
+	require.Equal(t, 1, res.Candidate.Blocked)
+	assert.Equal(t, 1, res.Candidate.BlockedByReason[risk.BlockVelocity])
+	assert.Equal(t, day.Add(11*time.Minute), res.Changes[0].CreatedAt)
+	assert.Equal(t, 0, res.ScoreChanged)
+}
+
+func TestBacktestReadsInBatches(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s, repo := newService(&now)
+	claimed(t, s, repo, now, `{}`)
+	var payments []domain.RiskBacktestPayment
+	for i := 0; i < batchSize+5; i++ {
+		payments = append(payments, payment(uuid.New(), 2, 100, time.Duration(i)*time.Second, domain.TransactionStatusCompleted))
+	}
+	last := payments[batchSize-1]
+	replays(repo, time.Time{}, uuid.Nil, payments[:batchSize])
+	replays(repo, last.CreatedAt, last.ID, payments[batchSize:])
+
+	b := run(t, s, repo)
+	assert.Equal(t, batchSize+5, b.Results.Transactions)
+	assert.Equal(t, 0, b.Results.BlockedDelta)
+	assert.Empty(t, b.Results.Changes)
+	// Progress after each batch, then the result
+	repo.AssertNumberOfCalls(t, "Update", 3)
+}
+
+func TestRunNext(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("nothing to run", func(t *testing.T) {
+		s, repo := newService(&now)
+		// Running backtests are only taken over once their lease runs out
+		repo.On("Claim", ctx, now, now.Add(-lease)).Return(nil, nil).Once()
+		repo.On("Claim", ctx, now, now.Add(-lease)).Return(nil, errors.New("database unavailable")).Once()
+		assert.False(t, s.RunNext(ctx))
+		assert.False(t, s.RunNext(ctx))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("a failed read fails the backtest", func(t *testing.T) {
+		s, repo := newService(&now)
+		claimed(t, s, repo, now, `{}`)
+		repo.On("Payments", ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(nil, errors.New("statement timeout")).Once()
+
+		b := run(t, s, repo)
+		assert.Equal(t, domain.RiskBacktestFailed, b.Status)
+		require.NotNil(t, b.Error)
+		assert.Equal(t, "statement timeout", *b.Error)
+		assert.Nil(t, b.Results)
+	})
+
+	t.Run("stopping leaves it for another worker", func(t *testing.T) {
+		s, repo := newService(&now)
+		claimed(t, s, repo, now, `{}`)
+		var payments []domain.RiskBacktestPayment
+		for i := 0; i < batchSize; i++ {
+			payments = append(payments, payment(uuid.New(), 2, 100, time.Duration(i)*time.Second, domain.TransactionStatusCompleted))
+		}
+		replays(repo, time.Time{}, uuid.Nil, payments)
+		repo.On("Update", ctx, mock.MatchedBy(func(b *domain.RiskBacktest) bool {
+			return b.Status == domain.RiskBacktestRunning && b.Processed == batchSize
+		})).Return(nil).Once()
+		s.Stop()
+
+		assert.False(t, s.RunNext(ctx))
+		repo.AssertExpectations(t)
+	})
+}
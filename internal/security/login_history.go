@@ -0,0 +1,85 @@
+package security
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChannelWeb    = "web"
+	ChannelMobile = "mobile"
+	ChannelAPI    = "api"
+)
+
+// LoginHistoryEntry is one sign-in attempt as shown to the account owner.
+type LoginHistoryEntry struct {
+	At         time.Time `json:"at"`
+	Successful bool      `json:"successful"`
+	Device     string    `json:"device,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	Location   string    `json:"location,omitempty"`
+	Channel    string    `json:"channel"`
+}
+
+// loginEventTypes are the security events that record sign-in attempts.
+var loginEventTypes = []string{
+	domain.SecurityEventTypeLoginSuccess,
+	domain.SecurityEventTypeAdminLoginFailed,
+}
+
+// GetLoginHistory returns the user's most recent sign-in attempts, newest first.
+func (s *Service) GetLoginHistory(ctx context.Context, userID uuid.UUID, limit int) ([]LoginHistoryEntry, error) {
+	filter := &EventFilter{UserID: &userID, Types: loginEventTypes}
+	events, _, err := s.repo.GetSecurityEvents(ctx, filter, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]LoginHistoryEntry, 0, len(events))
+	for _, e := range events {
+		entries = append(entries, loginHistoryEntry(e))
+	}
+	return entries, nil
+}
+
+func loginHistoryEntry(e domain.SecurityEvent) LoginHistoryEntry {
+	str := func(key string) string {
+		v, _ := e.Metadata[key].(string)
+		return v
+	}
+	entry := LoginHistoryEntry{
+		At:         e.CreatedAt,
+		Successful: e.Type == domain.SecurityEventTypeLoginSuccess,
+		Device:     str("device_name"),
+		IPAddress:  e.IPAddress,
+		Location:   str("country_code"),
+		Channel:    str("channel"),
+	}
+	if entry.Device == "" {
+		entry.Device = str("user_agent")
+	}
+	if entry.Channel == "" {
+		entry.Channel = LoginChannel(str("user_agent"))
+	}
+	return entry
+}
+
+// LoginChannel classifies a sign-in by its user agent.
+func LoginChannel(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return ChannelAPI
+	case strings.Contains(ua, "android") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") ||
+		strings.Contains(ua, "okhttp") || strings.Contains(ua, "cfnetwork") || strings.Contains(ua, "dart"):
+		return ChannelMobile
+	case strings.Contains(ua, "mozilla"):
+		return ChannelWeb
+	default:
+		return ChannelAPI
+	}
+}
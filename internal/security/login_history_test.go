@@ -0,0 +1,69 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type eventsOnlyRepository struct {
+	Repository
+	filter *EventFilter
+	events []domain.SecurityEvent
+}
+
+func (r *eventsOnlyRepository) GetSecurityEvents(_ context.Context, filter *EventFilter, limit, _ int) ([]domain.SecurityEvent, int, error) {
+	r.filter = filter
+	return r.events, len(r.events), nil
+}
+
+func TestGetLoginHistory(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	repo := &eventsOnlyRepository{events: []domain.SecurityEvent{
+		{
+			Type:      domain.SecurityEventTypeLoginSuccess,
+			IPAddress: "41.70.1.2",
+			CreatedAt: now,
+			Metadata: domain.Metadata{
+				"device_name":  "Mozilla/5.0 (Linux; Android 14; Pixel 8)",
+				"country_code": "MW",
+				"channel":      ChannelMobile,
+			},
+		},
+		{
+			Type:      domain.SecurityEventTypeAdminLoginFailed,
+			IPAddress: "102.1.1.1",
+			CreatedAt: now.Add(-time.Hour),
+			Metadata:  domain.Metadata{"user_agent": "Mozilla/5.0 (Windows NT 10.0)"},
+		},
+	}}
+
+	history, err := NewService(repo).GetLoginHistory(context.Background(), userID, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, &userID, repo.filter.UserID)
+	assert.ElementsMatch(t, []string{domain.SecurityEventTypeLoginSuccess, domain.SecurityEventTypeAdminLoginFailed}, repo.filter.Types)
+
+	assert.Len(t, history, 2)
+	assert.True(t, history[0].Successful)
+	assert.Equal(t, "MW", history[0].Location)
+	assert.Equal(t, ChannelMobile, history[0].Channel)
+
+	// Events recorded before channels were stored fall back to the user agent.
+	assert.False(t, history[1].Successful)
+	assert.Equal(t, ChannelWeb, history[1].Channel)
+	assert.Equal(t, "Mozilla/5.0 (Windows NT 10.0)", history[1].Device)
+}
+
+func TestLoginChannel(t *testing.T) {
+	assert.Equal(t, ChannelMobile, LoginChannel("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0)"))
+	assert.Equal(t, ChannelMobile, LoginChannel("okhttp/4.12.0"))
+	assert.Equal(t, ChannelWeb, LoginChannel("Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0)"))
+	assert.Equal(t, ChannelAPI, LoginChannel("curl/8.4.0"))
+	assert.Equal(t, ChannelAPI, LoginChannel(""))
+}
@@ -11,6 +11,7 @@ import (
 
 type EventFilter struct {
 	Type      *string
+	Types     []string // matches any of the listed event types
 	Severity  *string
 	Status    *string
 	UserID    *uuid.UUID
@@ -0,0 +1,130 @@
+package segments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// FlagRequest creates or replaces a feature flag.
+type FlagRequest struct {
+	Description string      `json:"description"`
+	Enabled     bool        `json:"enabled"`
+	Segments    []uuid.UUID `json:"segments"`
+}
+
+func (s *Service) ListFlags(ctx context.Context) ([]domain.FeatureFlag, error) {
+	snap, err := s.fresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]domain.FeatureFlag{}, snap.flags...), nil
+}
+
+// SetFlag creates or replaces a flag. Every listed segment must exist.
+func (s *Service) SetFlag(ctx context.Context, key string, req FlagRequest, updatedBy uuid.UUID) (*domain.FeatureFlag, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !keyPattern.MatchString(key) {
+		return nil, fmt.Errorf("%w: key must be 2-64 lowercase letters, digits, '-' or '_'", ErrInvalidFlag)
+	}
+	f := &domain.FeatureFlag{
+		Key:         key,
+		Description: strings.TrimSpace(req.Description),
+		Enabled:     req.Enabled,
+		Segments:    []string{},
+		UpdatedBy:   &updatedBy,
+		UpdatedAt:   s.now().UTC(),
+	}
+	seen := make(map[uuid.UUID]bool)
+	for _, id := range req.Segments {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := s.Get(ctx, id); err != nil {
+			return nil, fmt.Errorf("%w: unknown segment %s", ErrInvalidFlag, id)
+		}
+		f.Segments = append(f.Segments, id.String())
+	}
+	if err := s.repo.UpsertFlag(ctx, f); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return f, nil
+}
+
+func (s *Service) DeleteFlag(ctx context.Context, key string) error {
+	deleted, err := s.repo.DeleteFlag(ctx, strings.ToLower(key))
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFlagNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+// Features returns the enabled state of every flag for the user.
+func (s *Service) Features(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	snap, err := s.fresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var targeted []domain.Segment
+	wanted := make(map[uuid.UUID]bool)
+	for _, f := range snap.flags {
+		if !f.Enabled {
+			continue
+		}
+		for _, sid := range f.Segments {
+			id, err := uuid.Parse(sid)
+			if err != nil || wanted[id] {
+				continue
+			}
+			if seg, ok := snap.byID[id]; ok {
+				wanted[id] = true
+				targeted = append(targeted, *seg)
+			}
+		}
+	}
+	member := make(map[string]bool)
+	if len(targeted) > 0 {
+		ids, err := s.memberships(ctx, userID, targeted)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			member[id.String()] = true
+		}
+	}
+
+	out := make(map[string]bool, len(snap.flags))
+	for _, f := range snap.flags {
+		on := f.Enabled && len(f.Segments) == 0
+		if f.Enabled {
+			for _, sid := range f.Segments {
+				if member[sid] {
+					on = true
+					break
+				}
+			}
+		}
+		out[f.Key] = on
+	}
+	return out, nil
+}
+
+// FeatureEnabled reports whether the flag is on for the user. Unknown flags
+// are off.
+func (s *Service) FeatureEnabled(ctx context.Context, key string, userID uuid.UUID) (bool, error) {
+	features, err := s.Features(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return features[key], nil
+}
@@ -0,0 +1,331 @@
+// Package segments manages saved user segments and the feature flags that
+// target them, and answers which segments a user belongs to.
+//
+// Segment definitions are few and change rarely, so each instance keeps
+// them in memory and refreshes them every cache TTL. Membership is then
+// decided in process from one small user lookup, plus a volume sum only
+// when a relevant segment has volume bounds. Users with no segment that
+// changes fees or risk cost no query at all.
+package segments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrSegmentNotFound = errors.New("segment not found")
+	ErrInvalidSegment  = errors.New("invalid segment")
+	ErrSegmentExists   = errors.New("segment key already in use")
+	ErrSegmentInUse    = errors.New("segment is used by a feature flag")
+	ErrFlagNotFound    = errors.New("feature flag not found")
+	ErrInvalidFlag     = errors.New("invalid feature flag")
+)
+
+// Repository persists segments and flags and evaluates criteria in SQL.
+type Repository interface {
+	// CreateSegment returns ErrSegmentExists when the key is taken, as does
+	// UpdateSegment.
+	CreateSegment(ctx context.Context, seg *domain.Segment) error
+	UpdateSegment(ctx context.Context, seg *domain.Segment) error
+	DeleteSegment(ctx context.Context, id uuid.UUID) (bool, error)
+	ListSegments(ctx context.Context) ([]domain.Segment, error)
+	// CountMembers counts the users matching criteria.
+	CountMembers(ctx context.Context, criteria domain.SegmentCriteria) (int, error)
+	// GetSubject loads the user fields criteria look at: ID, type, KYC
+	// level, country, status, last login and sign-up time. Nil when the
+	// user does not exist.
+	GetSubject(ctx context.Context, userID uuid.UUID) (*domain.User, error)
+	// SentVolume sums the user's completed payments in currency since.
+	SentVolume(ctx context.Context, userID uuid.UUID, currency domain.Currency, since time.Time) (decimal.Decimal, error)
+
+	ListFlags(ctx context.Context) ([]domain.FeatureFlag, error)
+	UpsertFlag(ctx context.Context, f *domain.FeatureFlag) error
+	DeleteFlag(ctx context.Context, key string) (bool, error)
+}
+
+const defaultCacheTTL = time.Minute
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,63}$`)
+
+type Service struct {
+	repo   Repository
+	logger logger.Logger
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	cache    *snapshot
+	loadedAt time.Time
+}
+
+// snapshot is the in-memory copy of all segments and flags.
+type snapshot struct {
+	segments []domain.Segment
+	byID     map[uuid.UUID]*domain.Segment
+	flags    []domain.FeatureFlag
+}
+
+func NewService(repo Repository, log logger.Logger) *Service {
+	return &Service{repo: repo, logger: log, ttl: defaultCacheTTL, now: time.Now}
+}
+
+// WithCacheTTL sets how long segment definitions are served from memory.
+// Changes made on another instance take up to this long to apply here.
+func (s *Service) WithCacheTTL(d time.Duration) *Service {
+	if d > 0 {
+		s.ttl = d
+	}
+	return s
+}
+
+// SegmentRequest creates a segment or replaces one.
+type SegmentRequest struct {
+	Key            string                 `json:"key"`
+	Name           string                 `json:"name"`
+	Description    string                 `json:"description"`
+	Criteria       domain.SegmentCriteria `json:"criteria"`
+	FeeRate        *decimal.Decimal       `json:"fee_rate"`
+	RiskAdjustment int                    `json:"risk_adjustment"`
+}
+
+func (s *Service) Create(ctx context.Context, req SegmentRequest, createdBy uuid.UUID) (*domain.Segment, error) {
+	now := s.now().UTC()
+	seg := &domain.Segment{ID: uuid.New(), CreatedBy: &createdBy, CreatedAt: now, UpdatedAt: now}
+	if err := apply(seg, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateSegment(ctx, seg); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return seg, nil
+}
+
+// Update replaces a segment's definition. Broadcasts already scheduled keep
+// the criteria they were created with.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, req SegmentRequest) (*domain.Segment, error) {
+	seg, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(seg, req); err != nil {
+		return nil, err
+	}
+	seg.UpdatedAt = s.now().UTC()
+	if err := s.repo.UpdateSegment(ctx, seg); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return seg, nil
+}
+
+// Delete removes a segment that no feature flag refers to.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
+	snap, err := s.fresh(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range snap.flags {
+		for _, sid := range f.Segments {
+			if sid == id.String() {
+				return fmt.Errorf("%w: %s", ErrSegmentInUse, f.Key)
+			}
+		}
+	}
+	deleted, err := s.repo.DeleteSegment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSegmentNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+// Get returns a copy of a segment.
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.Segment, error) {
+	snap, err := s.fresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seg, ok := snap.byID[id]
+	if !ok {
+		return nil, ErrSegmentNotFound
+	}
+	cp := *seg
+	return &cp, nil
+}
+
+func (s *Service) List(ctx context.Context) ([]domain.Segment, error) {
+	snap, err := s.fresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append([]domain.Segment{}, snap.segments...), nil
+}
+
+// Preview counts the users criteria match right now.
+func (s *Service) Preview(ctx context.Context, criteria domain.SegmentCriteria) (int, error) {
+	if err := criteria.Normalize(); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidSegment, err)
+	}
+	return s.repo.CountMembers(ctx, criteria)
+}
+
+// IsMember reports whether the user is in the segment.
+func (s *Service) IsMember(ctx context.Context, segmentID, userID uuid.UUID) (bool, error) {
+	seg, err := s.Get(ctx, segmentID)
+	if err != nil {
+		return false, err
+	}
+	ids, err := s.memberships(ctx, userID, []domain.Segment{*seg})
+	if err != nil {
+		return false, err
+	}
+	return len(ids) == 1, nil
+}
+
+// PolicyFor combines the fee rate and risk adjustment of the user's
+// segments: the lowest fee rate applies and adjustments add up.
+func (s *Service) PolicyFor(ctx context.Context, userID uuid.UUID) (*domain.SegmentPolicy, error) {
+	snap, err := s.fresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var relevant []domain.Segment
+	for _, seg := range snap.segments {
+		if seg.FeeRate != nil || seg.RiskAdjustment != 0 {
+			relevant = append(relevant, seg)
+		}
+	}
+	policy := &domain.SegmentPolicy{}
+	if len(relevant) == 0 {
+		return policy, nil
+	}
+	ids, err := s.memberships(ctx, userID, relevant)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		seg := snap.byID[id]
+		policy.Segments = append(policy.Segments, id)
+		policy.RiskAdjustment += seg.RiskAdjustment
+		if seg.FeeRate != nil && (policy.FeeRate == nil || seg.FeeRate.LessThan(*policy.FeeRate)) {
+			rate := *seg.FeeRate
+			policy.FeeRate = &rate
+		}
+	}
+	return policy, nil
+}
+
+// memberships returns the IDs of the candidates userID belongs to.
+func (s *Service) memberships(ctx context.Context, userID uuid.UUID, candidates []domain.Segment) ([]uuid.UUID, error) {
+	user, err := s.repo.GetSubject(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	now := s.now()
+	volumes := make(map[domain.Currency]decimal.Decimal)
+	var ids []uuid.UUID
+	for _, seg := range candidates {
+		c := seg.Criteria
+		volume := decimal.Zero
+		if c.NeedsVolume() {
+			v, ok := volumes[c.VolumeCurrency]
+			if !ok {
+				if v, err = s.repo.SentVolume(ctx, userID, c.VolumeCurrency, now.Add(-domain.SegmentVolumeWindow)); err != nil {
+					return nil, err
+				}
+				volumes[c.VolumeCurrency] = v
+			}
+			volume = v
+		}
+		if c.Matches(user, volume, now) {
+			ids = append(ids, seg.ID)
+		}
+	}
+	return ids, nil
+}
+
+func apply(seg *domain.Segment, req SegmentRequest) error {
+	key := strings.ToLower(strings.TrimSpace(req.Key))
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("%w: key must be 2-64 lowercase letters, digits, '-' or '_'", ErrInvalidSegment)
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSegment)
+	}
+	criteria := req.Criteria
+	if err := criteria.Normalize(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSegment, err)
+	}
+	if req.FeeRate != nil && (req.FeeRate.IsNegative() || req.FeeRate.GreaterThanOrEqual(decimal.NewFromInt(1))) {
+		return fmt.Errorf("%w: fee_rate must be at least 0 and below 1", ErrInvalidSegment)
+	}
+	if req.RiskAdjustment < -100 || req.RiskAdjustment > 100 {
+		return fmt.Errorf("%w: risk_adjustment must be between -100 and 100", ErrInvalidSegment)
+	}
+	seg.Key = key
+	seg.Name = name
+	seg.Description = strings.TrimSpace(req.Description)
+	seg.Criteria = criteria
+	seg.FeeRate = req.FeeRate
+	seg.RiskAdjustment = req.RiskAdjustment
+	return nil
+}
+
+// fresh returns the cached snapshot, reloading it once the TTL has passed.
+func (s *Service) fresh(ctx context.Context) (*snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && s.now().Sub(s.loadedAt) < s.ttl {
+		return s.cache, nil
+	}
+	segs, err := s.repo.ListSegments(ctx)
+	if err != nil {
+		if s.cache != nil {
+			// Serve the last good copy rather than fail every payment.
+			s.logger.Warn("Failed to refresh segments, serving cached copy", map[string]interface{}{"error": err.Error()})
+			return s.cache, nil
+		}
+		return nil, err
+	}
+	flags, err := s.repo.ListFlags(ctx)
+	if err != nil {
+		if s.cache != nil {
+			s.logger.Warn("Failed to refresh feature flags, serving cached copy", map[string]interface{}{"error": err.Error()})
+			return s.cache, nil
+		}
+		return nil, err
+	}
+	snap := &snapshot{segments: segs, byID: make(map[uuid.UUID]*domain.Segment, len(segs)), flags: flags}
+	for i := range snap.segments {
+		snap.byID[snap.segments[i].ID] = &snap.segments[i]
+	}
+	s.cache = snap
+	s.loadedAt = s.now()
+	return snap, nil
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,345 @@
+package segments
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateSegment(ctx context.Context, seg *domain.Segment) error {
+	return m.Called(ctx, seg).Error(0)
+}
+
+func (m *MockRepository) UpdateSegment(ctx context.Context, seg *domain.Segment) error {
+	return m.Called(ctx, seg).Error(0)
+}
+
+func (m *MockRepository) DeleteSegment(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ListSegments(ctx context.Context) ([]domain.Segment, error) {
+	args := m.Called(ctx)
+	segs, _ := args.Get(0).([]domain.Segment)
+	return segs, args.Error(1)
+}
+
+func (m *MockRepository) CountMembers(ctx context.Context, criteria domain.SegmentCriteria) (int, error) {
+	args := m.Called(ctx, criteria)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) GetSubject(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, userID)
+	u, _ := args.Get(0).(*domain.User)
+	return u, args.Error(1)
+}
+
+func (m *MockRepository) SentVolume(ctx context.Context, userID uuid.UUID, currency domain.Currency, since time.Time) (decimal.Decimal, error) {
+	args := m.Called(ctx, userID, currency, since)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func (m *MockRepository) ListFlags(ctx context.Context) ([]domain.FeatureFlag, error) {
+	args := m.Called(ctx)
+	flags, _ := args.Get(0).([]domain.FeatureFlag)
+	return flags, args.Error(1)
+}
+
+func (m *MockRepository) UpsertFlag(ctx context.Context, f *domain.FeatureFlag) error {
+	return m.Called(ctx, f).Error(0)
+}
+
+func (m *MockRepository) DeleteFlag(ctx context.Context, key string) (bool, error) {
+	args := m.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	admin = uuid.New()
+)
+
+func newService(now *time.Time) (*Service, *MockRepository) {
+	repo := new(MockRepository)
+	s := NewService(repo, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo
+}
+
+// stored serves segs and flags as the saved definitions, loaded once.
+func stored(repo *MockRepository, segs []domain.Segment, flags []domain.FeatureFlag) {
+	repo.On("ListSegments", ctx).Return(segs, nil).Once()
+	repo.On("ListFlags", ctx).Return(flags, nil).Once()
+}
+
+func rate(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+
+func segment(key string, criteria domain.SegmentCriteria, feeRate *decimal.Decimal, riskAdjustment int) domain.Segment {
+	return domain.Segment{ID: uuid.New(), Key: key, Name: key, Criteria: criteria, FeeRate: feeRate, RiskAdjustment: riskAdjustment}
+}
+
+func user(country string, kyc int, created time.Time) *domain.User {
+	return &domain.User{
+		ID:          uuid.New(),
+		UserType:    domain.UserTypeIndividual,
+		KYCLevel:    kyc,
+		CountryCode: country,
+		IsActive:    true,
+		UserStatus:  domain.UserStatusActive,
+		CreatedAt:   created,
+	}
+}
+
+func TestCreateValidates(t *testing.T) {
+	now := start
+	bad := []SegmentRequest{
+		{Key: "X", Name: "n"},
+		{Key: "vip", Name: " "},
+		{Key: "vip", Name: "VIP", Criteria: domain.SegmentCriteria{Countries: []string{"Malawi"}}},
+		{Key: "vip", Name: "VIP", Criteria: domain.SegmentCriteria{MinVolume: rate("100")}},
+		{Key: "vip", Name: "VIP", Criteria: domain.SegmentCriteria{MinAccountAgeDays: 30, MaxAccountAgeDays: 10}},
+		{Key: "vip", Name: "VIP", FeeRate: rate("1.5")},
+		{Key: "vip", Name: "VIP", RiskAdjustment: 101},
+	}
+	for _, req := range bad {
+		s, repo := newService(&now)
+		_, err := s.Create(ctx, req, admin)
+		assert.ErrorIs(t, err, ErrInvalidSegment)
+		repo.AssertNotCalled(t, "CreateSegment", mock.Anything, mock.Anything)
+	}
+
+	s, repo := newService(&now)
+	repo.On("CreateSegment", ctx, mock.MatchedBy(func(seg *domain.Segment) bool {
+		return seg.Key == "mw-high-value" && seg.Name == "Malawi high value" &&
+			assert.ObjectsAreEqual([]string{"MW"}, seg.Criteria.Countries) && seg.Criteria.VolumeCurrency == domain.MWK &&
+			*seg.CreatedBy == admin && seg.CreatedAt.Equal(now)
+	})).Return(nil).Once()
+	repo.On("CreateSegment", ctx, mock.Anything).Return(ErrSegmentExists).Once()
+
+	_, err := s.Create(ctx, SegmentRequest{
+		Key:      "MW-High-Value",
+		Name:     " Malawi high value ",
+		Criteria: domain.SegmentCriteria{Countries: []string{" mw"}, VolumeCurrency: "mwk", MinVolume: rate("1000000")},
+	}, admin)
+	require.NoError(t, err)
+	_, err = s.Create(ctx, SegmentRequest{Key: "mw-high-value", Name: "Again"}, admin)
+	assert.ErrorIs(t, err, ErrSegmentExists)
+	repo.AssertExpectations(t)
+}
+
+func TestPolicyFor(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	newUser := user("MW", 1, now.AddDate(0, 0, -2))
+	veteran := user("MW", 2, now.AddDate(0, 0, -400))
+	newAccounts := segment("new-accounts", domain.SegmentCriteria{MaxAccountAgeDays: 30}, nil, 20)
+	highVolume := segment("high-volume", domain.SegmentCriteria{VolumeCurrency: domain.MWK, MinVolume: rate("1000000")}, rate("0.008"), -10)
+	lowVolume := segment("low-volume", domain.SegmentCriteria{VolumeCurrency: domain.MWK, MaxVolume: rate("1000")}, rate("0.02"), 0)
+	tierTwo := segment("tier-two", domain.SegmentCriteria{KYCLevels: []int{2}}, rate("0.012"), 0)
+	// No fee or risk effect, so never evaluated for policies
+	everyone := segment("everyone", domain.SegmentCriteria{}, nil, 0)
+	stored(repo, []domain.Segment{newAccounts, highVolume, lowVolume, tierTwo, everyone}, nil)
+	since := now.Add(-domain.SegmentVolumeWindow)
+
+	repo.On("GetSubject", ctx, newUser.ID).Return(newUser, nil).Once()
+	repo.On("SentVolume", ctx, newUser.ID, domain.MWK, since).Return(decimal.Zero, nil).Once()
+	p, err := s.PolicyFor(ctx, newUser.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{newAccounts.ID, lowVolume.ID}, p.Segments)
+	assert.Equal(t, "0.02", p.FeeRate.String())
+	assert.Equal(t, 20, p.RiskAdjustment)
+
+	repo.On("GetSubject", ctx, veteran.ID).Return(veteran, nil).Once()
+	repo.On("SentVolume", ctx, veteran.ID, domain.MWK, since).Return(decimal.NewFromInt(2_000_000), nil).Once()
+	p, err = s.PolicyFor(ctx, veteran.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{highVolume.ID, tierTwo.ID}, p.Segments)
+	assert.Equal(t, "0.008", p.FeeRate.String(), "the lowest rate applies")
+	assert.Equal(t, -10, p.RiskAdjustment)
+
+	unknown := uuid.New()
+	repo.On("GetSubject", ctx, unknown).Return(nil, nil).Once()
+	p, err = s.PolicyFor(ctx, unknown)
+	require.NoError(t, err)
+	assert.Empty(t, p.Segments)
+	repo.AssertExpectations(t)
+
+	t.Run("nothing changes fees or risk", func(t *testing.T) {
+		s, repo := newService(&now)
+		stored(repo, []domain.Segment{everyone}, nil)
+		for i := 0; i < 3; i++ {
+			p, err := s.PolicyFor(ctx, veteran.ID)
+			require.NoError(t, err)
+			assert.Empty(t, p.Segments)
+		}
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "GetSubject", mock.Anything, mock.Anything)
+	})
+
+	t.Run("volume cannot be summed", func(t *testing.T) {
+		s, repo := newService(&now)
+		stored(repo, []domain.Segment{highVolume}, nil)
+		repo.On("GetSubject", ctx, veteran.ID).Return(veteran, nil).Once()
+		repo.On("SentVolume", ctx, veteran.ID, domain.MWK, since).Return(decimal.Zero, errors.New("db down")).Once()
+		_, err := s.PolicyFor(ctx, veteran.ID)
+		assert.Error(t, err)
+	})
+}
+
+func TestCache(t *testing.T) {
+	now := start
+	everyone := segment("everyone", domain.SegmentCriteria{}, nil, 0)
+
+	t.Run("reloads after the TTL", func(t *testing.T) {
+		s, repo := newService(&now)
+		stored(repo, []domain.Segment{everyone}, nil)
+		_, err := s.List(ctx)
+		require.NoError(t, err)
+		now = now.Add(defaultCacheTTL - time.Second)
+		_, err = s.List(ctx)
+		require.NoError(t, err)
+		repo.AssertNumberOfCalls(t, "ListSegments", 1)
+
+		stored(repo, []domain.Segment{everyone}, nil)
+		now = now.Add(time.Second)
+		_, err = s.List(ctx)
+		require.NoError(t, err)
+		repo.AssertNumberOfCalls(t, "ListSegments", 2)
+	})
+
+	t.Run("serves the last good copy when a reload fails", func(t *testing.T) {
+		s, repo := newService(&now)
+		stored(repo, []domain.Segment{everyone}, nil)
+		_, err := s.List(ctx)
+		require.NoError(t, err)
+
+		repo.On("ListSegments", ctx).Return(nil, errors.New("db down")).Once()
+		now = now.Add(2 * defaultCacheTTL)
+		list, err := s.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, list, 1)
+	})
+
+	t.Run("fails without a copy", func(t *testing.T) {
+		s, repo := newService(&now)
+		repo.On("ListSegments", ctx).Return(nil, errors.New("db down")).Once()
+		_, err := s.List(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("writes reload at once", func(t *testing.T) {
+		s, repo := newService(&now)
+		stored(repo, nil, nil)
+		_, err := s.List(ctx)
+		require.NoError(t, err)
+		repo.On("CreateSegment", ctx, mock.Anything).Return(nil).Once()
+		_, err = s.Create(ctx, SegmentRequest{Key: "everyone", Name: "Everyone"}, admin)
+		require.NoError(t, err)
+
+		stored(repo, []domain.Segment{everyone}, nil)
+		list, err := s.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, list, 1)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestFeatures(t *testing.T) {
+	now := start
+	zambian := user("ZM", 1, now.Add(-time.Hour))
+	malawian := user("MW", 1, now.Add(-time.Hour))
+	zm := segment("zambia", domain.SegmentCriteria{Countries: []string{"ZM"}}, nil, 0)
+	flags := []domain.FeatureFlag{
+		{Key: "new-home", Enabled: true, Segments: pq.StringArray{}},
+		{Key: "zm-corridor", Enabled: true, Segments: pq.StringArray{zm.ID.String()}},
+		{Key: "dark-launch", Enabled: false, Segments: pq.StringArray{zm.ID.String()}},
+	}
+
+	s, repo := newService(&now)
+	stored(repo, []domain.Segment{zm}, flags)
+	repo.On("GetSubject", ctx, zambian.ID).Return(zambian, nil).Once()
+	f, err := s.Features(ctx, zambian.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"new-home": true, "zm-corridor": true, "dark-launch": false}, f)
+
+	repo.On("GetSubject", ctx, malawian.ID).Return(malawian, nil).Once()
+	on, err := s.FeatureEnabled(ctx, "zm-corridor", malawian.ID)
+	require.NoError(t, err)
+	assert.False(t, on)
+	repo.AssertExpectations(t)
+
+	t.Run("a segment in use cannot be deleted", func(t *testing.T) {
+		s, repo := newService(&now)
+		stored(repo, []domain.Segment{zm}, flags)
+		assert.ErrorIs(t, s.Delete(ctx, zm.ID), ErrSegmentInUse)
+		repo.AssertNotCalled(t, "DeleteSegment", mock.Anything, mock.Anything)
+	})
+
+	t.Run("set and delete", func(t *testing.T) {
+		s, repo := newService(&now)
+		stored(repo, []domain.Segment{zm}, nil)
+		_, err := s.SetFlag(ctx, "bad", FlagRequest{Enabled: true, Segments: []uuid.UUID{uuid.New()}}, admin)
+		assert.ErrorIs(t, err, ErrInvalidFlag)
+
+		repo.On("UpsertFlag", ctx, mock.MatchedBy(func(f *domain.FeatureFlag) bool {
+			return f.Key == "zm-corridor" && f.Enabled && len(f.Segments) == 1 && f.Segments[0] == zm.ID.String() && *f.UpdatedBy == admin
+		})).Return(nil).Once()
+		_, err = s.SetFlag(ctx, " ZM-Corridor ", FlagRequest{Enabled: true, Segments: []uuid.UUID{zm.ID, zm.ID}}, admin)
+		require.NoError(t, err)
+
+		repo.On("DeleteFlag", ctx, "zm-corridor").Return(true, nil).Once()
+		repo.On("DeleteFlag", ctx, "zm-corridor").Return(false, nil).Once()
+		require.NoError(t, s.DeleteFlag(ctx, "ZM-Corridor"))
+		assert.ErrorIs(t, s.DeleteFlag(ctx, "zm-corridor"), ErrFlagNotFound)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestCriteriaMatches(t *testing.T) {
+	now := time.Now()
+	login := now.Add(-2 * 24 * time.Hour)
+	u := &domain.User{
+		UserType: domain.UserTypeMerchant, KYCLevel: 2, CountryCode: "MW",
+		IsActive: true, UserStatus: domain.UserStatusActive,
+		LastLogin: &login, CreatedAt: now.Add(-90 * 24 * time.Hour),
+	}
+	vol := decimal.NewFromInt(500)
+
+	assert.True(t, domain.SegmentCriteria{}.Matches(u, vol, now))
+	assert.True(t, domain.SegmentCriteria{Countries: []string{"ZM", "MW"}, KYCLevels: []int{2}, UserTypes: []domain.UserType{domain.UserTypeMerchant}}.Matches(u, vol, now))
+	assert.False(t, domain.SegmentCriteria{Countries: []string{"ZM"}}.Matches(u, vol, now))
+	assert.True(t, domain.SegmentCriteria{ActiveWithinDays: 7}.Matches(u, vol, now))
+	assert.False(t, domain.SegmentCriteria{ActiveWithinDays: 1}.Matches(u, vol, now))
+	assert.True(t, domain.SegmentCriteria{MinAccountAgeDays: 60, MaxAccountAgeDays: 120}.Matches(u, vol, now))
+	assert.False(t, domain.SegmentCriteria{MaxAccountAgeDays: 30}.Matches(u, vol, now))
+	assert.True(t, domain.SegmentCriteria{MinVolume: rate("500"), MaxVolume: rate("1000")}.Matches(u, vol, now))
+	assert.False(t, domain.SegmentCriteria{MinVolume: rate("501")}.Matches(u, vol, now))
+
+	admin := *u
+	admin.UserType = domain.UserTypeAdmin
+	assert.False(t, domain.SegmentCriteria{}.Matches(&admin, vol, now))
+	suspended := *u
+	suspended.UserStatus = domain.UserStatusSuspended
+	assert.False(t, domain.SegmentCriteria{}.Matches(&suspended, vol, now))
+}
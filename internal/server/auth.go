@@ -0,0 +1,235 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"kyd/internal/auth"
+	"kyd/internal/domain"
+	kydgrpc "kyd/internal/grpc"
+	"kyd/internal/grpc/kydv1"
+	"kyd/internal/handler"
+	"kyd/internal/middleware"
+	"kyd/internal/repository/postgres"
+	"kyd/internal/security"
+	"kyd/internal/userimport"
+	"kyd/pkg/bootstrap"
+	"kyd/pkg/errors"
+	"kyd/pkg/mailer"
+	"kyd/pkg/sms"
+	"kyd/pkg/validator"
+)
+
+// Auth builds the auth service's router. app must be connected to the
+// database and Redis.
+func Auth(app *bootstrap.App) (http.Handler, error) {
+	cfg, log := app.Config, app.Logger
+	db := app.DB
+
+	// Initialize security crypto service
+	cryptoService, err := security.NewCryptoService()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize crypto service")
+	}
+
+	// Initialize repositories
+	userRepo := postgres.NewUserRepository(db, cryptoService)
+	auditRepo := postgres.NewAuditRepository(db, cryptoService)
+	securityRepo := postgres.NewSecurityRepository(db)
+
+	// Initialize token blacklist
+	blacklist := app.TokenBlacklist()
+
+	// Initialize services
+	authService := auth.NewService(userRepo, blacklist, cfg.JWT.Secret, cfg.JWT.Expiration).WithAdditionalJWTSecrets(cfg.JWT.OldSecrets)
+	securityService := security.NewService(securityRepo)
+
+	// Configure email verification and password reset
+	m, err := mailer.New(mailer.Config{
+		Host:                 cfg.Email.SMTPHost,
+		Port:                 cfg.Email.SMTPPort,
+		Username:             cfg.Email.SMTPUsername,
+		Password:             cfg.Email.SMTPPassword,
+		From:                 cfg.Email.SMTPFrom,
+		UseTLS:               cfg.Email.SMTPUseTLS,
+		GmailAPIEnabled:      cfg.Email.GmailAPIEnabled,
+		GmailCredentialsPath: cfg.Email.GmailCredentialsPath,
+		GmailTokenPath:       cfg.Email.GmailTokenPath,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize mailer")
+	}
+	m = m.WithTracking(postgres.NewEmailDeliveryRepository(db, cryptoService), mailer.RetryPolicy{
+		MaxAttempts: cfg.Email.RetryMaxAttempts,
+		BaseDelay:   cfg.Email.RetryBaseDelay,
+		MaxDelay:    cfg.Email.RetryMaxDelay,
+	})
+	app.Start(mailer.NewRetryWorker(m, cfg.Email.RetryInterval, 50, log))
+
+	authService = authService.WithEmailVerification(m, cfg.Verification.BaseURL, cfg.Verification.TokenExpiration, cfg.Verification.BypassEmailVerification).
+		WithVerificationPolicy(auth.VerificationPolicyFromConfig(cfg.Verification)).
+		WithVerificationStore(postgres.NewEmailVerificationRepository(db)).
+		WithPhoneVerification(sms.NewLogSender(log), postgres.NewPhoneVerificationRepository(db), cfg.Verification.PhoneCodeExpiry, cfg.Verification.PhoneCodeMaxAttempts)
+	authService = authService.WithPasswordReset(cfg.PasswordReset.BaseURL, cfg.PasswordReset.TokenExpiration).
+		WithInvitations(cfg.Invitation.BaseURL, cfg.Invitation.Expiry)
+
+	passwordPolicy, err := auth.PasswordPolicyFromConfig(cfg.Password)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load password policy")
+	}
+	authService = authService.WithPasswordPolicy(passwordPolicy).
+		WithEligibility(auth.EligibilityPolicyFromConfig(cfg.Eligibility), postgres.NewEligibilityRepository(db, cryptoService)).
+		WithMFA(auth.MFASettingsFromConfig(cfg.MFA), postgres.NewMFARepository(db)).
+		WithDevices(userRepo).
+		WithStepUpLockout(userRepo, auditRepo, auth.StepUpLockout{
+			MaxAttempts: cfg.Payment.StepUpMaxAttempts,
+			Duration:    cfg.Payment.StepUpLockout,
+		})
+	if cfg.Password.BreachCheckEnabled {
+		authService = authService.WithBreachChecker(auth.NewRangeBreachChecker(cfg.Password.BreachCheckURL, cfg.Password.BreachCheckTimeout))
+	}
+
+	// Initialize Google OAuth Service
+	if cfg.Google.MockMode || (cfg.Google.ClientID != "" && cfg.Google.ClientSecret != "") {
+		googleOAuthConfig := &auth.GoogleOAuthConfig{
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			RedirectURI:  cfg.Google.RedirectURI,
+			TokenIssuer:  cfg.Google.TokenIssuer,
+			MockMode:     cfg.Google.MockMode,
+		}
+		googleOAuthService, err := auth.NewGoogleOAuthService(googleOAuthConfig, authService)
+		if err != nil {
+			log.Error("Failed to initialize Google OAuth Service", map[string]interface{}{"error": err.Error()})
+		} else {
+			authService = authService.WithGoogleOAuth(googleOAuthService)
+		}
+	}
+
+	// Initialize handlers
+	val := validator.New()
+	env := strings.ToLower(strings.TrimSpace(os.Getenv("ENV")))
+	if env == "" {
+		env = "local"
+	}
+	cookieSecure := envBool("COOKIE_SECURE", env != "local")
+	authHandler := handler.NewAuthHandler(authService, val, log, auditRepo, securityService, cfg.TOTP.Issuer, cfg.TOTP.Period, cfg.TOTP.Digits, cookieSecure)
+	usersHandler := handler.NewUsersHandler(authService, val, log, auditRepo, nil, nil, nil)
+	eligibilityHandler := handler.NewEligibilityHandler(authService, val, log)
+
+	// Bulk user imports, invited in the background
+	userImports := userimport.NewService(postgres.NewUserImportRepository(db, cryptoService), userRepo, authService, cfg.UserImport, log)
+	app.Start(userImports)
+	userImportsHandler := handler.NewUserImportsHandler(userImports, log)
+
+	// Token introspection for the other services
+	if cfg.GRPC.Enabled {
+		kydv1.RegisterAuthServiceServer(app.GRPC(), kydgrpc.NewAuthServer(authService))
+	}
+
+	enableRateLimiter := envBool("AUTH_RATE_LIMIT_ENABLED", env != "local")
+
+	var rateLimiter *middleware.RateLimiter
+	if enableRateLimiter {
+		// Secure auth rate limit: max 5 attempts per 15 minutes
+		rateLimiter = app.RateLimiter("auth", 5, 15*time.Minute).WithAdaptive(3, 30*time.Minute)
+	}
+	r := app.NewRouter(bootstrap.RouterConfig{RateLimiter: rateLimiter})
+
+	// Routes
+	r.HandleFunc("/api/v1/auth/health", app.Health).Methods("GET")
+	r.HandleFunc("/api/v1/auth/register", authHandler.Register).Methods("POST")
+	r.HandleFunc("/api/v1/auth/login", authHandler.Login).Methods("POST")
+	r.HandleFunc("/api/v1/auth/logout", authHandler.Logout).Methods("POST")
+	r.HandleFunc("/api/v1/auth/mfa/send-code", authHandler.SendMFACode).Methods("POST")
+	r.HandleFunc("/api/v1/auth/mfa/verify", authHandler.VerifyMFA).Methods("POST")
+	r.HandleFunc("/api/v1/auth/send-verification", authHandler.SendVerification).Methods("POST")
+	r.HandleFunc("/api/v1/auth/verify/resend", authHandler.SendVerification).Methods("POST")
+	r.HandleFunc("/api/v1/auth/verify", authHandler.VerifyEmail).Methods("POST", "GET")
+	r.HandleFunc("/api/v1/auth/forgot-password", authHandler.ForgotPassword).Methods("POST")
+	r.HandleFunc("/api/v1/auth/reset-password", authHandler.ResetPassword).Methods("POST")
+	r.HandleFunc("/api/v1/auth/invitations", authHandler.Invitation).Methods("GET")
+	r.HandleFunc("/api/v1/auth/invitations/accept", authHandler.AcceptInvitation).Methods("POST")
+	r.HandleFunc("/api/v1/auth/eligibility/appeals", eligibilityHandler.Appeal).Methods("POST")
+
+	// Google OAuth routes
+	r.HandleFunc("/api/v1/auth/google/start", authHandler.GoogleAuthStart).Methods("GET")
+	r.HandleFunc("/api/v1/auth/google/callback", authHandler.GoogleAuthCallback).Methods("POST")
+	r.HandleFunc("/api/v1/auth/google/mock-login", authHandler.GoogleMockLogin).Methods("GET")
+
+	r.HandleFunc("/api/v1/auth/debug", authHandler.DebugUser).Methods("GET")
+
+	// Protected routes
+	authMW := middleware.NewAuthMiddlewareWithUserStatus(cfg.JWT.Secret, blacklist, &userStatusChecker{repo: userRepo, log: log})
+	auditMW := middleware.NewAuditMiddleware(auditRepo, log)
+	authz := middleware.NewAuthorizer(newRoles(app, userRepo), log)
+	staff := func(perm domain.Permission, fn http.HandlerFunc) http.Handler {
+		return authz.Require(perm)(fn)
+	}
+
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/auth/health", app.Health).Methods("GET")
+	api.Use(auditMW.Audit)
+	api.Use(authMW.Authenticate)
+	api.Use(authz.Load)
+	api.HandleFunc("/auth/me", authHandler.Me).Methods("GET")
+	api.HandleFunc("/auth/me", usersHandler.UpdateMe).Methods("PUT")
+	api.HandleFunc("/auth/me/password", usersHandler.ChangeMyPassword).Methods("POST")
+	api.HandleFunc("/auth/login-history", authHandler.LoginHistory).Methods("GET")
+	api.HandleFunc("/auth/phone/send-code", authHandler.SendPhoneVerification).Methods("POST")
+	api.HandleFunc("/auth/phone/verify", authHandler.VerifyPhone).Methods("POST")
+	api.HandleFunc("/auth/totp/setup", authHandler.SetupTOTP).Methods("POST")
+	api.HandleFunc("/auth/totp/verify", authHandler.VerifyTOTP).Methods("POST")
+	api.HandleFunc("/auth/totp/disable", authHandler.DisableTOTP).Methods("POST")
+	api.HandleFunc("/auth/totp/status", authHandler.TOTPStatus).Methods("GET")
+	api.HandleFunc("/auth/mfa/status", authHandler.MFAStatus).Methods("GET")
+	api.HandleFunc("/auth/mfa/backup-codes", authHandler.RegenerateBackupCodes).Methods("POST")
+	api.HandleFunc("/auth/devices", authHandler.ListDevices).Methods("GET")
+	api.HandleFunc("/auth/devices/{id}", authHandler.RenameDevice).Methods("PUT")
+	api.HandleFunc("/auth/devices/{id}", authHandler.RevokeDevice).Methods("DELETE")
+	api.HandleFunc("/auth/devices/{id}/trust/send-code", authHandler.SendDeviceTrustCode).Methods("POST")
+	api.HandleFunc("/auth/devices/{id}/trust", authHandler.TrustDevice).Methods("POST")
+	api.HandleFunc("/auth/devices/{id}/untrust", authHandler.UntrustDevice).Methods("POST")
+	api.Handle("/auth/mfa/policy", staff(domain.PermissionSystemRead, authHandler.GetMFAPolicy)).Methods("GET")
+	api.Handle("/auth/mfa/policy", staff(domain.PermissionSystemWrite, authHandler.SetMFAPolicy)).Methods("PUT")
+	// Admin user management
+	api.Handle("/auth/users", staff(domain.PermissionUsersRead, usersHandler.List)).Methods("GET")
+	api.Handle("/auth/users/{id}", staff(domain.PermissionUsersRead, usersHandler.Get)).Methods("GET")
+	api.Handle("/auth/users/{id}", staff(domain.PermissionUsersWrite, usersHandler.Update)).Methods("PUT")
+	api.Handle("/auth/users/{id}/block", staff(domain.PermissionUsersWrite, usersHandler.BlockUser)).Methods("POST")
+	api.Handle("/auth/users/{id}/unblock", staff(domain.PermissionUsersWrite, usersHandler.UnblockUser)).Methods("POST")
+	// Admin bulk user imports
+	api.Handle("/auth/imports", staff(domain.PermissionUsersWrite, userImportsHandler.Upload)).Methods("POST")
+	api.Handle("/auth/imports", staff(domain.PermissionUsersRead, userImportsHandler.List)).Methods("GET")
+	api.Handle("/auth/imports/{id}", staff(domain.PermissionUsersRead, userImportsHandler.Get)).Methods("GET")
+	api.Handle("/auth/imports/{id}/rows", staff(domain.PermissionUsersRead, userImportsHandler.Rows)).Methods("GET")
+	// Admin email delivery
+	api.Handle("/auth/emails", staff(domain.PermissionUsersRead, usersHandler.ListEmails)).Methods("GET")
+	api.Handle("/auth/emails/{id}/resend", staff(domain.PermissionUsersWrite, usersHandler.ResendEmail)).Methods("POST")
+	// Admin registration eligibility
+	api.Handle("/auth/eligibility/rules", staff(domain.PermissionComplianceRead, eligibilityHandler.ListRules)).Methods("GET")
+	api.Handle("/auth/eligibility/rules/{country}", staff(domain.PermissionComplianceWrite, eligibilityHandler.SetRule)).Methods("PUT")
+	api.Handle("/auth/eligibility/rules/{country}", staff(domain.PermissionComplianceWrite, eligibilityHandler.DeleteRule)).Methods("DELETE")
+	api.Handle("/auth/eligibility/rejections", staff(domain.PermissionComplianceRead, eligibilityHandler.ListRejections)).Methods("GET")
+	api.Handle("/auth/eligibility/rejections/{id}/decision", staff(domain.PermissionComplianceWrite, eligibilityHandler.DecideAppeal)).Methods("POST")
+
+	return r, nil
+}
+
+// simple env bool parsing (duplicate to avoid exporting internal helpers)
+func envBool(key string, def bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "1", "true", "yes", "y", "on":
+		return true
+	case "0", "false", "no", "n", "off":
+		return false
+	default:
+		return def
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"kyd/internal/forex"
+	kydgrpc "kyd/internal/grpc"
+	"kyd/internal/grpc/kydv1"
+	"kyd/internal/handler"
+	"kyd/internal/heartbeat"
+	"kyd/internal/middleware"
+	"kyd/internal/repository/postgres"
+	"kyd/internal/spreads"
+	"kyd/pkg/bootstrap"
+	"kyd/pkg/errors"
+	"kyd/pkg/validator"
+)
+
+// Forex builds the forex service's router. app must be connected to the
+// database and Redis.
+func Forex(app *bootstrap.App) (http.Handler, error) {
+	cfg, log := app.Config, app.Logger
+	db, redisClient := app.DB, app.Redis
+
+	// Initialize repositories
+	forexRepo := postgres.NewForexRepository(db)
+	history, err := forex.HistoryConfigFromConfig(cfg.Forex)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid FOREX_SNAPSHOT_PAIRS")
+	}
+
+	// Initialize rate providers (Google Finance first, then API fallback)
+	providers := []forex.RateProvider{
+		forex.NewGoogleFinanceProvider(),
+		forex.NewExchangeRateAPIProvider(),
+	}
+
+	// Initialize services
+	// Wrap redis client with the RateCache adapter; stored rates are
+	// announced on the rate bus so other instances refresh their caches.
+	rateCache := forex.NewRedisRateCache(redisClient)
+	rateBus := forex.NewRedisRateBus(redisClient)
+	forexService := forex.NewService(forexRepo, rateCache, providers, log).
+		WithRateBus(rateBus).
+		WithLocalMaxAge(cfg.Forex.LocalCacheMaxAge).
+		WithFailover(forex.FailoverConfigFromConfig(cfg.Forex)).
+		WithRateHistory(postgres.NewRateHistoryRepository(db), postgres.NewTransactionRepository(db), history).
+		WithSpreads(spreads.NewService(postgres.NewFXSpreadRepository(db), log))
+	app.Start(forex.NewRateSubscriber(forexService, rateBus, log))
+
+	// Rates are sampled into the chart history here only, so that the
+	// payment service's copy of the forex service does not sample them too.
+	heartbeats := heartbeat.NewMonitor(postgres.NewHeartbeatRepository(db), "forex", cfg.Heartbeat, log)
+	app.Start(heartbeats)
+	app.Start(heartbeats.Watch("rate_snapshot", history.SnapshotInterval, func(p heartbeat.Pulse) heartbeat.Worker {
+		return forex.NewSnapshotWorker(forexService, history.SnapshotInterval, log).WithHeartbeat(p)
+	}))
+
+	// Initialize handlers
+	val := validator.New()
+	forexHandler := handler.NewForexHandler(forexService, val, log)
+
+	// Internal gRPC API over the same forex service
+	if cfg.GRPC.Enabled {
+		kydv1.RegisterForexServiceServer(app.GRPC(), kydgrpc.NewForexServer(forexService, val))
+	}
+
+	r := app.NewRouter(bootstrap.RouterConfig{
+		RateLimiter: app.RateLimiter("forex", 100, time.Minute),
+		// The rates socket lives for the whole session, so it must not
+		// inherit a request deadline.
+		Deadlines: middleware.NewQueryDeadlines(app.DBConfig.QueryTimeout).WithPrefix("/api/v1/forex/ws", 0),
+	})
+
+	// Public routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/forex/rates", forexHandler.GetAllRates).Methods("GET")
+	api.HandleFunc("/forex/rate/{from}/{to}", forexHandler.GetRate).Methods("GET")
+	api.HandleFunc("/forex/rate", forexHandler.GetRateQuery).Methods("GET")
+	api.HandleFunc("/forex/calculate", forexHandler.Calculate).Methods("POST")
+	api.HandleFunc("/forex/history", forexHandler.GetHistory).Methods("GET")
+	api.HandleFunc("/forex/history/{from}/{to}", forexHandler.GetHistory).Methods("GET")
+	api.HandleFunc("/forex/ohlc", forexHandler.GetOHLC).Methods("GET")
+
+	// WebSocket for real-time rates
+	api.HandleFunc("/forex/ws", forexHandler.WebSocketHandler)
+
+	return r, nil
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/invoice"
+	"kyd/internal/payment"
+)
+
+// invoicePayer pays invoices through the payment service, as a payment from
+// the payer to the merchant in the invoice's currency.
+type invoicePayer struct {
+	payments *payment.Service
+}
+
+func (p invoicePayer) Pay(ctx context.Context, inv *invoice.Payment) (*domain.Transaction, error) {
+	req := &payment.InitiatePaymentRequest{
+		SenderID:            inv.PayerID,
+		ReceiverID:          inv.MerchantID,
+		Amount:              inv.Amount,
+		Currency:            inv.Currency,
+		DestinationCurrency: inv.Currency,
+		Description:         inv.Description,
+		Channel:             "invoice",
+		Reference:           inv.Reference,
+		Metadata:            inv.Metadata,
+	}
+	if inv.StepUp != nil {
+		req.StepUp = &payment.StepUp{Password: inv.StepUp.Password, TOTPCode: inv.StepUp.TOTPCode}
+	}
+	resp, err := p.payments.InitiatePayment(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Transaction, nil
+}
@@ -0,0 +1,1092 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"kyd/internal/aml"
+	"kyd/internal/analytics"
+	"kyd/internal/auth"
+	"kyd/internal/billing"
+	"kyd/internal/blockchain"
+	"kyd/internal/blockchain/ripple"
+	"kyd/internal/blockchain/stellar"
+	"kyd/internal/broadcast"
+	"kyd/internal/casework"
+	"kyd/internal/complaint"
+	"kyd/internal/compliance"
+	"kyd/internal/counterparty"
+	"kyd/internal/credit"
+	"kyd/internal/dashboard"
+	"kyd/internal/datasharing"
+	"kyd/internal/developer"
+	"kyd/internal/domain"
+	"kyd/internal/dormancy"
+	"kyd/internal/export"
+	"kyd/internal/fees"
+	"kyd/internal/filechannel"
+	"kyd/internal/forex"
+	kydgrpc "kyd/internal/grpc"
+	"kyd/internal/grpc/kydv1"
+	"kyd/internal/handler"
+	"kyd/internal/heartbeat"
+	"kyd/internal/integrity"
+	"kyd/internal/ledger"
+	"kyd/internal/freeze"
+	"kyd/internal/jurisdiction"
+	"kyd/internal/maintenance"
+	"kyd/internal/middleware"
+	"kyd/internal/monitoring"
+	"kyd/internal/notification"
+	"kyd/internal/outbox"
+	"kyd/internal/payment"
+	"kyd/internal/invoice"
+	"kyd/internal/paymentbatch"
+	"kyd/internal/plans"
+	"kyd/internal/rbac"
+	"kyd/internal/reconciliation"
+	"kyd/internal/recovery"
+	"kyd/internal/repository/postgres"
+	"kyd/internal/risk"
+	"kyd/internal/riskbacktest"
+	"kyd/internal/security"
+	"kyd/internal/segments"
+	"kyd/internal/spreads"
+	"kyd/internal/settlement"
+	"kyd/internal/standing"
+	"kyd/internal/statement"
+	"kyd/internal/txstream"
+	"kyd/internal/virusscan"
+	"kyd/internal/wallet"
+	"kyd/internal/webhook"
+	"kyd/pkg/bootstrap"
+	"kyd/pkg/errors"
+	"kyd/pkg/sms"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// Payment builds the payment service's router, which also serves KYC,
+// notifications, exports and the admin API, and starts its background
+// workers. app must be connected to the database and Redis.
+func Payment(app *bootstrap.App) (http.Handler, error) {
+	cfg, log := app.Config, app.Logger
+	db, redisClient := app.DB, app.Redis
+
+	// Initialize security service
+	cryptoService, err := security.NewCryptoService()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize crypto service")
+	}
+
+	// Initialize repositories
+	txRepo := postgres.NewTransactionRepository(db)
+	walletRepo := postgres.NewWalletRepository(db)
+	forexRepo := postgres.NewForexRepository(db)
+	userRepo := postgres.NewUserRepository(db, cryptoService)
+	settlementRepo := postgres.NewSettlementRepository(db)
+	auditRepo := postgres.NewAuditRepository(db, cryptoService)
+	ledgerRepo := postgres.NewLedgerRepository(db)
+	securityRepo := postgres.NewSecurityRepository(db)
+	blockchainRepo := postgres.NewBlockchainNetworkRepository(db)
+	kycRepo := postgres.NewKYCRepository(db)
+	if cfg.Outbox.Enabled {
+		txRepo.WithOutbox()
+		userRepo.WithOutbox()
+		settlementRepo.WithOutbox()
+	}
+	newActivityProjector(cfg.Activity, app, cryptoService, txRepo, userRepo, walletRepo, log)
+	apiKeyRepo := postgres.NewAPIKeyRepository(db)
+	settlementRouteRepo := postgres.NewSettlementRouteRepository(db)
+	txNoteRepo := postgres.NewTransactionNoteRepository(db)
+
+	// Outbound webhooks fire as events are appended to the transaction stream,
+	webhookRepo := postgres.NewWebhookRepository(db)
+	webhookService := webhook.NewService(webhookRepo, txRepo, webhook.ConfigFromConfig(cfg.Webhook), log)
+	// and are pushed to the parties' open transaction streams on every
+	// instance
+	txUpdates := txstream.NewRedisBus(redisClient)
+	txEventRepo := txstream.NewStream(webhookService.Stream(postgres.NewTransactionEventRepository(db)), txRepo, txUpdates, log)
+	if cfg.Webhook.Enabled {
+		app.Start(webhookService)
+	}
+	txHub := txstream.NewHub(txUpdates, log)
+	app.Start(txHub)
+
+	// Initialize services
+	ledgerService := ledger.NewService(db, ledgerRepo)
+	// The fee collector's wallets are the platform's fee income, not money
+	// owed to customers
+	if v := strings.TrimSpace(os.Getenv("TREASURY_FEE_USER_ID")); v != "" {
+		if id, err := uuid.Parse(v); err == nil {
+			if _, err := ledgerService.DesignateHouseUser(context.Background(), id, domain.AccountFeeIncome); err != nil {
+				log.Warn("Failed to book fee collector wallets as fee income", map[string]interface{}{"error": err.Error()})
+			}
+		}
+	}
+	securityService := security.NewService(securityRepo)
+	blockchainService := blockchain.NewService(blockchainRepo)
+	// Download links for KYC documents and exports are signed with the
+	// same secret
+	signingSecret := cfg.Security.SigningSecret
+	if signingSecret == "" {
+		signingSecret = cfg.JWT.Secret
+	}
+	kycFiles := compliance.UploadDir{
+		Dir:           "./uploads/kyc",
+		QuarantineDir: "./uploads/quarantine",
+	}
+	complianceService := compliance.NewService(kycRepo, userRepo, auditRepo).
+		WithReviewQueue(kycRepo).
+		WithDocumentLinks(kycFiles, signingSecret, cfg.Compliance.DocumentURLExpiry)
+	scanner, err := virusscan.FromConfig(cfg.VirusScan)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize virus scanner")
+	}
+	if scanner != nil {
+		complianceService = complianceService.WithScanner(scanner, kycFiles)
+	}
+	if cfg.Compliance.EnableSanctionsCheck {
+		screener, err := aml.FromConfig(cfg.Compliance.AML, log)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize aml provider")
+		}
+		if screener != nil {
+			if w, ok := screener.(bootstrap.Worker); ok {
+				app.Start(w)
+			}
+			complianceService = complianceService.WithScreening(screener, kycRepo)
+		}
+	}
+	planService := plans.NewService(postgres.NewPlanRepository(db), userRepo, log)
+	developerRepo := postgres.NewDeveloperRepository(db)
+	apiKeyService := auth.NewAPIKeyService(apiKeyRepo).WithEntitlements(planService).
+		WithEnvironments(developerRepo).
+		WithCallLog(developerRepo)
+
+	blacklist := app.TokenBlacklist()
+	authService := auth.NewService(userRepo, blacklist, cfg.JWT.Secret, 24*time.Hour)
+	passwordPolicy, err := auth.PasswordPolicyFromConfig(cfg.Password)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load password policy")
+	}
+	authService = authService.WithPasswordPolicy(passwordPolicy).
+		WithStepUpLockout(userRepo, auditRepo, auth.StepUpLockout{
+			MaxAttempts: cfg.Payment.StepUpMaxAttempts,
+			Duration:    cfg.Payment.StepUpLockout,
+		})
+	if cfg.Password.BreachCheckEnabled {
+		authService = authService.WithBreachChecker(auth.NewRangeBreachChecker(cfg.Password.BreachCheckURL, cfg.Password.BreachCheckTimeout))
+	}
+
+	// Initialize blockchain connectors
+	stellarConnector, err := stellar.NewConnector(
+		"", // force local-only connector (no external network)
+		cfg.Stellar.SecretKey,
+		true, // always simulate locally
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Stellar connector")
+	}
+
+	rippleConnector, err := ripple.NewConnector(
+		"", // force local-only connector (no external network)
+		cfg.Ripple.SecretKey,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Ripple connector")
+	}
+
+	// Maintenance windows, shared with the other services through Redis
+	maintenanceMode := maintenance.NewMode(maintenance.NewRedisStore(redisClient), cfg.Maintenance)
+
+	// Emergency money movement freezes, approved by a second admin
+	freezeService := freeze.NewService(postgres.NewFreezeRepository(db), cfg.Freeze, log)
+
+	// Embargoed and blocked countries, each restricted between its effective
+	// dates
+	jurisdictionService := jurisdiction.NewService(postgres.NewJurisdictionRepository(db), cfg.Jurisdiction, log)
+
+	// Settlement partners, payout providers and correspondent banks; settlements
+	// wait while their corridor's counterparties are suspended, at their exposure
+	// limits or short of prefunding
+	counterpartyService := counterparty.NewService(postgres.NewCounterpartyRepository(db), log).
+		WithRates(forexRepo).
+		WithExposureWarning(cfg.ExposureAlerts.WarnAt)
+
+	// Initialize Settlement Service (Background Worker)
+	corridorModes, err := settlement.ParseCorridorModes(cfg.Settlement.CorridorModes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SETTLEMENT_CORRIDOR_MODES")
+	}
+	settlementRouter := settlement.NewRouter(settlementRouteRepo, domain.NetworkStellar, domain.NetworkRipple)
+	settlementService := settlement.NewService(
+		settlementRepo,
+		txRepo,
+		stellarConnector,
+		rippleConnector,
+		log,
+	).WithFeePolicy(settlement.FeePolicyFromConfig(cfg.Settlement)).
+		WithThrottle(settlement.NewThrottle(settlement.ThrottleConfigFromConfig(cfg.Settlement), blockchainService)).
+		WithRouter(settlementRouter).
+		WithCorridorModes(corridorModes, postgres.NewSettlementNettingRepository(db)).
+		WithCounterparties(counterpartyService).
+		WithEventStream(txEventRepo).
+		WithMaintenance(maintenanceMode).
+		WithFreezes(freezeService).
+		WithJurisdictions(jurisdictionService)
+
+	rateHistory, err := forex.HistoryConfigFromConfig(cfg.Forex)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid FOREX_SNAPSHOT_PAIRS")
+	}
+	// Initialize forex providers
+	forexProviders := []forex.RateProvider{
+		forex.NewGoogleFinanceProvider(), // Try Google Finance first
+		forex.NewMockRateProvider(),
+		forex.NewExchangeRateAPIProvider(),
+	}
+
+	// Initialize Notification Service (persisted notifications + audit trail)
+	notificationRepo := postgres.NewNotificationRepository(db)
+	notificationService := notification.NewService(log, auditRepo, notificationRepo).
+		WithSMS(sms.NewLogSender(log), userRepo).
+		WithPreferences(notificationRepo).
+		WithExperiments(notificationRepo)
+	if cfg.Push.Enabled {
+		pushers, err := notification.PushSenders(context.Background(), cfg.Push, log)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize push notifications")
+		}
+		notificationService.WithPush(pushers, notificationRepo)
+	}
+	complianceService.WithNotifier(notificationService)
+
+	// Case management (admin operations)
+	caseRepo := postgres.NewCaseRepository(db)
+	caseService := casework.NewService(caseRepo)
+
+	// Saved user segments, shared by fees, risk scoring, feature flags and broadcasts
+	segmentService := segments.NewService(postgres.NewSegmentRepository(db), log)
+	// Fee schedules pricing payments by corridor, channel, user type and KYC level
+	feeService := fees.NewService(postgres.NewFeeScheduleRepository(db), log)
+	// FX spreads set per currency pair, applied to the forex mid rates
+	spreadService := spreads.NewService(postgres.NewFXSpreadRepository(db), log)
+
+	// Broadcast messaging to user segments (admin operations)
+	broadcastService := broadcast.NewService(postgres.NewBroadcastRepository(db), notificationService, log).
+		WithSegments(segmentService)
+	app.Start(broadcastService)
+
+	// Asynchronous admin exports and partner reports, whose links are also
+	// pushed to the partner's webhooks when ready
+	exportRepo := postgres.NewExportJobRepository(db)
+	exportService := export.NewService(exportRepo, cfg.Export, signingSecret, log).
+		WithSource(domain.ExportKindAuditLogs, export.NewAuditLogSource(auditRepo)).
+		WithSource(domain.ExportKindTransactions, export.NewTransactionSource(txRepo, txNoteRepo)).
+		WithSource(domain.ExportKindSettlementReport, export.NewSettlementReportSource(txRepo)).
+		WithSource(domain.ExportKindFeeReport, export.NewFeeReportSource(txRepo)).
+		WithSchedules(exportRepo).
+		WithNotifier(webhookService)
+	app.Start(exportService)
+
+	// Business metric anomaly alerts (volume drops, decline and latency spikes)
+	metricRepo := postgres.NewMetricRepository(db)
+	metricDetector := monitoring.NewMetricDetector(metricRepo, securityRepo, notificationService, cfg.MetricAlerts, log)
+	app.Start(metricDetector)
+
+	// Counterparty exposure alerts at the soft threshold and the hard limit
+	exposureMonitor := monitoring.NewExposureMonitor(counterpartyService, metricRepo, securityRepo, notificationService, cfg.ExposureAlerts, log)
+	app.Start(exposureMonitor)
+
+	// Redis outage alerts; the middleware meanwhile follow their failure modes
+	if app.RedisGuard != nil {
+		app.RedisGuard.WithAlerts(monitoring.NewRedisAlerts(metricRepo, securityRepo, notificationService, log))
+	}
+
+	// Scheduled database integrity and orphan checks
+	integrityService := integrity.NewService(postgres.NewIntegrityRepository(db), cfg.Integrity, log).
+		WithDocumentFiles(kycFiles)
+	app.Start(integrityService)
+
+	// Scheduled reconciliation of wallet balances against the ledger
+	reconciliationService := reconciliation.NewService(postgres.NewReconciliationRepository(db), cfg.Reconciliation, log)
+	if cfg.Reconciliation.Alerts {
+		reconciliationService.WithAlerts(metricRepo, notificationService)
+	}
+	app.Start(reconciliationService)
+
+	// Background workers beat; this process's are restarted when they stall
+	// and every process's stalls are alerted on. The monitor starts before
+	// the workers it watches so it stops after them.
+	heartbeatRepo := postgres.NewHeartbeatRepository(db)
+	heartbeats := heartbeat.NewMonitor(heartbeatRepo, "payment", cfg.Heartbeat, log)
+	app.Start(heartbeats)
+	watchdog := heartbeat.NewWatchdog(heartbeatRepo, cfg.Heartbeat, log)
+	if cfg.Heartbeat.Alerts {
+		watchdog.WithAlerts(metricRepo, notificationService)
+	}
+	app.Start(watchdog)
+
+	// Wrap redis client with RateCache adapter
+	rateCache := forex.NewRedisRateCache(redisClient)
+	rateBus := forex.NewRedisRateBus(redisClient)
+	forexService := forex.NewService(forexRepo, rateCache, forexProviders, log).
+		WithRateBus(rateBus).
+		WithLocalMaxAge(cfg.Forex.LocalCacheMaxAge).
+		WithFailover(forex.FailoverConfigFromConfig(cfg.Forex)).
+		WithRateHistory(postgres.NewRateHistoryRepository(db), txRepo, rateHistory).
+		WithSpreads(spreadService)
+	app.Start(forex.NewRateSubscriber(forexService, rateBus, log))
+
+	cutOffs, err := payment.ParseCutOffWindows(cfg.Payment.CutOffWindows)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CORRIDOR_CUTOFF_WINDOWS")
+	}
+	paymentService := payment.NewService(txRepo, walletRepo, forexService, ledgerService, userRepo, notificationService, auditRepo, securityRepo, log, cfg).
+		WithEventStream(txEventRepo).
+		WithNotes(txNoteRepo).
+		WithSpendingControls(postgres.NewSpendingControlRepository(db)).
+		WithStepUp(authService, payment.StepUpPolicy{
+			Threshold:        decimal.NewFromInt(cfg.Payment.StepUpThreshold),
+			TrustedMaxAmount: decimal.NewFromInt(cfg.Payment.TrustedBeneficiaryMaxAmount),
+			CoolingOff:       cfg.Payment.TrustedBeneficiaryCoolingOff,
+		}).
+		WithTrustedBeneficiaries(postgres.NewTrustedBeneficiaryRepository(db)).
+		WithEntitlements(planService).
+		WithSegments(segmentService).
+		WithFeeSchedules(feeService).
+		WithInitiationBudget(cfg.Payment.InitiationBudget).
+		WithEscrows(txRepo, ledgerService).
+		WithCutOffs(cutOffs, txRepo).
+		WithSagas(postgres.NewPaymentSagaRepository(db)).
+		WithFreezes(freezeService).
+		WithJurisdictions(jurisdictionService).
+		WithDisclosures(postgres.NewPaymentDisclosureRepository(db), settlementService, cfg.Payment.DisclosureValidity).
+		WithQuotes(payment.NewRedisQuoteStore(redisClient), cfg.Payment.QuoteTTL).
+		WithBalanceHistory(postgres.NewBalanceHistoryRepository(db), payment.NewRedisAccountStateCache(redisClient), cfg.Payment.AccountStateCacheTTL)
+	// Payments interrupted by a crash are finished at startup and then
+	// periodically. Each pass is safe to repeat, so the workers are
+	// restarted if they stall.
+	app.Start(heartbeats.Watch("saga_recovery", cfg.Payment.SagaRecoveryInterval, func(p heartbeat.Pulse) heartbeat.Worker {
+		return payment.NewSagaRecoveryWorker(paymentService, cfg.Payment.SagaRecoveryAfter, cfg.Payment.SagaRecoveryInterval, log).WithHeartbeat(p)
+	}))
+	app.Start(heartbeats.Watch("escrow_expiry", cfg.Payment.EscrowExpiryInterval, func(p heartbeat.Pulse) heartbeat.Worker {
+		return payment.NewEscrowExpiryWorker(paymentService, cfg.Payment.EscrowExpiryInterval, log).WithHeartbeat(p)
+	}))
+	// Each period's balance snapshots are taken once, whichever instance
+	// gets there first.
+	app.Start(heartbeats.Watch("balance_snapshots", cfg.Payment.BalanceSnapshotInterval, func(p heartbeat.Pulse) heartbeat.Worker {
+		return payment.NewBalanceSnapshotWorker(paymentService, cfg.Payment.BalanceSnapshotInterval, cfg.Payment.BalanceSnapshotRetention, log).WithHeartbeat(p)
+	}))
+	// Domain events written to the outbox are published to the broker;
+	// dispatching is at least once, so a stalled dispatcher is restarted.
+	outboxRepo := postgres.NewOutboxRepository(db)
+	if cfg.Outbox.Enabled {
+		broker, err := outbox.NewBroker(cfg.Outbox.Broker, cfg.Outbox.BrokerURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid OUTBOX_BROKER")
+		}
+		app.Start(heartbeats.Watch("outbox_dispatcher", cfg.Outbox.PollInterval, func(p heartbeat.Pulse) heartbeat.Worker {
+			return outbox.NewDispatcher(outboxRepo, broker, cfg.Outbox, log).WithHeartbeat(p)
+		}))
+	}
+	if len(cutOffs) > 0 {
+		app.Start(heartbeats.Watch("queue_release", cfg.Payment.QueueReleaseInterval, func(p heartbeat.Pulse) heartbeat.Worker {
+			return payment.NewQueueReleaseWorker(paymentService, cfg.Payment.QueueReleaseInterval, log).WithHeartbeat(p)
+		}))
+	}
+	// Candidate risk rules replayed over past payments; the live rules are
+	// the engine the payment service just set up.
+	riskBacktestService := riskbacktest.NewService(postgres.NewRiskBacktestRepository(db), risk.GetDefaultRiskEngine(), log)
+	app.Start(riskBacktestService)
+	walletService := wallet.NewService(walletRepo, txRepo, userRepo, log)
+	fundingService, err := newFundingService(cfg.Funding, txRepo, walletRepo, userRepo, ledgerService, log)
+	if err != nil {
+		return nil, err
+	}
+	fundingService.WithFreezes(freezeService).
+		WithScreening(securityRepo, postgres.NewSpendingControlRepository(db), risk.GetDefaultRiskEngine())
+
+	// Stuck transactions are recovered where that is safe and escalated
+	// to the exception queue otherwise
+	recoveryService := recovery.NewService(postgres.NewRecoveryRepository(db), paymentService, fundingService, cfg.Recovery, log)
+	app.Start(recoveryService)
+
+	// Dormant accounts are frozen and their balances eventually escheated
+	dormancyService := dormancy.NewService(postgres.NewDormancyRepository(db), ledgerService, notificationService, cfg.Dormancy, log)
+	app.Start(dormancyService)
+
+	// Overdraft facilities accrue interest daily and are collected and
+	// chased for repayment by the worker
+	creditService := credit.NewService(postgres.NewCreditRepository(db), ledgerService, userRepo, walletRepo, notificationService, cfg.Credit, log)
+	app.Start(creditService)
+
+	// Customers' standing instructions convert or sweep money as it
+	// arrives in their wallets
+	standingService := standing.NewService(postgres.NewStandingInstructionRepository(db), standingPayments{paymentService}, walletRepo, notificationService, cfg.Standing, log)
+	app.Start(standingService)
+
+	// Files of payments customers upload are paid item by item in the
+	// background
+	paymentBatchService := paymentbatch.NewService(postgres.NewPaymentBatchRepository(db), userRepo, walletRepo, batchPayer{paymentService}, notificationService, cfg.PaymentBatch, log)
+	app.Start(paymentBatchService)
+
+	// Merchants' invoices are paid through their links; sent ones past
+	// their expiry are closed by the worker
+	invoiceService := invoice.NewService(postgres.NewMerchantInvoiceRepository(db), userRepo, walletRepo, invoicePayer{paymentService}, notificationService, cfg.Invoice, log)
+	app.Start(invoiceService)
+
+	// The complaints register; each quarter's return to the Reserve Bank
+	// is generated once the quarter ends
+	complaintService := complaint.NewService(postgres.NewComplaintRepository(db), txRepo, userRepo, notificationService, cfg.Complaint, log)
+	app.Start(complaintService)
+
+	// Users share transaction summaries with credit-scoring partners
+	dataSharingService := datasharing.NewService(postgres.NewDataSharingRepository(db), log)
+
+	// Bulk payment files from corporates that can only do SFTP or bucket drops
+	fileChannel := filechannel.NewService(filechannel.FromConfig(cfg.FileChannel), paymentService, userRepo, cfg.FileChannel.PollInterval, log).
+		WithFiles(postgres.NewBulkFileRepository(db))
+	if cfg.FileChannel.Enabled {
+		app.Start(fileChannel)
+	}
+
+	// Daily statements of corporate wallets for their ERPs, posted to the
+	// owner's webhooks or dropped in their file channel's outbound folder
+	statementService := statement.NewService(postgres.NewStatementRepository(db), walletRepo, userRepo, cfg.Statement, log)
+	if cfg.Webhook.Enabled {
+		statementService.WithWebhooks(webhookService)
+	}
+	if cfg.FileChannel.Enabled {
+		statementService.WithDrop(filechannel.FromConfig(cfg.FileChannel), filechannel.FolderOutbound)
+	}
+	if cfg.Statement.Enabled {
+		app.Start(statementService)
+	}
+
+	billingService := billing.NewService(postgres.NewBillingRepository(db), planService, paymentService, billing.Pricing{
+		Currency:         domain.Currency(cfg.Billing.Currency),
+		IncludedAPICalls: int64(cfg.Billing.IncludedAPICalls),
+		APICallPrice:     decimal.NewFromFloat(cfg.Billing.APICallPrice),
+		PayoutVolumeRate: decimal.NewFromFloat(cfg.Billing.PayoutVolumeRate),
+		PaymentTerms:     cfg.Billing.PaymentTerms,
+		RetryInterval:    cfg.Billing.RetryInterval,
+		MaxAttempts:      cfg.Billing.MaxAttempts,
+	}, log).
+		WithNotifier(notificationService).
+		WithInterval(cfg.Billing.RunInterval)
+	planService.WithHook(billingService)
+	paymentService.WithUsageMeter(billingService)
+	apiKeyService.WithUsageMeter(billingService)
+	if cfg.Billing.Enabled {
+		app.Start(billingService)
+	}
+
+	// Initialize handlers
+	val := validator.New()
+	paymentHandler := handler.NewPaymentHandler(paymentService, val, log)
+	walletHandler := handler.NewWalletHandler(walletService, val, log)
+	fundingHandler := handler.NewFundingHandler(fundingService, log)
+	securityHandler := handler.NewSecurityHandler(securityService, val)
+	settlementHandler := handler.NewSettlementHandler(settlementService, log)
+	settlementRouteHandler := handler.NewSettlementRouteHandler(settlementRouter, log)
+	counterpartiesHandler := handler.NewCounterpartiesHandler(counterpartyService, log)
+	forexHandler := handler.NewForexHandler(forexService, val, log)
+	blockchainHandler := handler.NewBlockchainHandler(blockchainService, ledgerService)
+	ledgerHandler := handler.NewLedgerHandler(ledgerService, log)
+	complianceHandler := handler.NewComplianceHandler(complianceService, log)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, log)
+	plansHandler := handler.NewPlansHandler(planService, log)
+	billingHandler := handler.NewBillingHandler(billingService, log)
+	webhooksHandler := handler.NewWebhooksHandler(webhookService, log)
+	notificationHandler := handler.NewNotificationHandler(notificationService, notificationRepo, log)
+	systemHandler := handler.NewSystemHandler(db, redisClient, auditRepo, notificationRepo, log)
+	usersHandler := handler.NewUsersHandler(authService, val, log, auditRepo, walletService, paymentService, securityService)
+	casesHandler := handler.NewCasesHandler(caseService)
+	broadcastHandler := handler.NewBroadcastHandler(broadcastService, log)
+	segmentsHandler := handler.NewSegmentsHandler(segmentService, log)
+	feeScheduleHandler := handler.NewFeeScheduleHandler(feeService, log)
+	fxSpreadHandler := handler.NewFXSpreadHandler(spreadService, log)
+	maintenanceHandler := handler.NewMaintenanceHandler(maintenanceMode, log)
+	freezeHandler := handler.NewFreezeHandler(freezeService, log)
+	jurisdictionHandler := handler.NewJurisdictionHandler(jurisdictionService, log)
+	exportHandler := handler.NewExportHandler(exportService, log)
+	reportsHandler := handler.NewReportsHandler(exportService, log)
+	statementsHandler := handler.NewStatementsHandler(statementService, log)
+	riskBacktestHandler := handler.NewRiskBacktestHandler(riskBacktestService, log)
+	bulkFilesHandler := handler.NewBulkFilesHandler(fileChannel, log)
+	integrityHandler := handler.NewIntegrityHandler(integrityService, log)
+	reconciliationHandler := handler.NewReconciliationHandler(reconciliationService, log)
+	workersHandler := handler.NewWorkersHandler(watchdog, log)
+	outboxHandler := handler.NewOutboxHandler(outboxRepo, log)
+	developerHandler := handler.NewDeveloperHandler(developer.NewService(apiKeyService, developerRepo, webhookRepo), cfg.Export.PublicURL, log)
+	recoveryHandler := handler.NewRecoveryHandler(recoveryService, log)
+	dormancyHandler := handler.NewDormancyHandler(dormancyService, log)
+	dataSharingHandler := handler.NewDataSharingHandler(dataSharingService, val, log)
+	creditHandler := handler.NewCreditHandler(creditService, val, log)
+	standingHandler := handler.NewStandingInstructionHandler(standingService, val, log)
+	paymentBatchHandler := handler.NewPaymentBatchHandler(paymentBatchService, log)
+	invoiceHandler := handler.NewInvoiceHandler(invoiceService, log)
+	complaintHandler := handler.NewComplaintHandler(complaintService, log)
+	txStreamHandler := handler.NewTransactionStreamHandler(txHub, log)
+
+	// Internal gRPC API over the same payment service
+	if cfg.GRPC.Enabled {
+		kydv1.RegisterPaymentServiceServer(app.GRPC(), kydgrpc.NewPaymentServer(paymentService, val).WithMaintenance(maintenanceMode))
+	}
+
+	// Initialize analytics
+	analyticsEngine := analytics.NewAnalyticsEngine()
+	analyticsReports := analytics.NewReports(postgres.NewAnalyticsRepository(db), log)
+	app.Start(analyticsReports)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsEngine, log).WithReports(analyticsReports)
+
+	// Admin dashboard aggregation
+	dashboardService := dashboard.NewService(log).
+		WithSection(dashboard.SectionSystemStats, dashboard.SystemStats(paymentService)).
+		WithSection(dashboard.SectionPendingKYC, dashboard.PendingKYC(complianceService)).
+		WithSection(dashboard.SectionRiskAlerts, dashboard.RiskAlerts(paymentService)).
+		WithSection(dashboard.SectionSettlementQueue, dashboard.SettlementQueue(settlementService)).
+		WithSection(dashboard.SectionNetworkHealth, dashboard.NetworkHealth(settlementService))
+	homeCorridors, err := dashboard.ParseCorridors(cfg.Home.Corridors)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid HOME_RATE_CORRIDORS")
+	}
+	home := dashboard.NewHome(walletService, paymentService, userRepo, forexService, log).WithCorridors(homeCorridors)
+	dashboardHandler := handler.NewDashboardHandler(dashboardService).WithHome(home)
+
+	// Background: System Health Collector
+	go func() {
+		ticker := time.NewTicker(60 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-app.Context().Done():
+				return
+			case <-ticker.C:
+			}
+			// DB ping latency
+			start := time.Now()
+			_ = db.Ping()
+			dbLatency := time.Since(start).Seconds()
+			_ = securityService.RecordHealthSnapshot(context.Background(), &domain.SystemHealthMetric{
+				MetricName: "db_ping_latency_seconds",
+				Value:      fmt.Sprintf("%.3f", dbLatency),
+				Status:     "healthy",
+				RecordedAt: time.Now(),
+			})
+
+			// Redis ping latency
+			start = time.Now()
+			_ = redisClient.Ping(context.Background()).Err()
+			redisLatency := time.Since(start).Seconds()
+			_ = securityService.RecordHealthSnapshot(context.Background(), &domain.SystemHealthMetric{
+				MetricName: "redis_ping_latency_seconds",
+				Value:      fmt.Sprintf("%.3f", redisLatency),
+				Status:     "healthy",
+				RecordedAt: time.Now(),
+			})
+		}
+	}()
+
+	serviceLimiter := app.RateLimiter("payment", 150, time.Minute).WithAdaptive(10, 30*time.Minute)
+	apiLimiter := app.RateLimiter("payment-api", 60, time.Minute).WithAdaptive(5, 15*time.Minute)
+	limitsHandler := handler.NewLimitsHandler(paymentService, log).
+		WithRateLimiter(serviceLimiter, false).
+		WithRateLimiter(apiLimiter, true)
+
+	r := app.NewRouter(bootstrap.RouterConfig{
+		RateLimiter: serviceLimiter,
+		Deadlines: middleware.NewQueryDeadlines(app.DBConfig.QueryTimeout).
+			WithPrefix("/api/v1/admin", app.DBConfig.ReportTimeout).
+			// The transaction stream lives for the whole session
+			WithPrefix("/api/v1/payments/stream", 0),
+	})
+
+	userStatus := &userStatusChecker{repo: userRepo, log: log, adminsActive: true}
+	authMW := middleware.NewAuthMiddlewareWithUserStatus(cfg.JWT.Secret, blacklist, userStatus).
+		WithAPIKeys(apiKeyService, userStatus)
+	// Payment initiation stays idempotent without Redis, its keys kept in
+	// the database meanwhile
+	idemMW := middleware.NewIdempotencyMiddleware(redisClient, 24*time.Hour).
+		WithFailureMode(middleware.FailureMode(cfg.Redis.IdempotencyFailure)).
+		WithFallback(postgres.NewIdempotencyRepository(db), "/api/v1/payments", "/api/v1/payments/initiate")
+	auditMW := middleware.NewAuditMiddleware(auditRepo, log)
+	roles := newRoles(app, userRepo).WithAudit(auditRepo)
+	authz := middleware.NewAuthorizer(roles, log)
+	rolesHandler := handler.NewRolesHandler(roles, log)
+
+	// Export and KYC document downloads are authorised by a signed link, not
+	// a session, so the routes sit outside the authenticated API.
+	r.HandleFunc("/api/v1/exports/{id}/download", exportHandler.Download).Methods("GET", "HEAD")
+	r.HandleFunc("/api/v1/kyc/documents/{id}/files/{side}", complianceHandler.DownloadDocument).Methods("GET")
+
+	// Payment providers sign their deposit and payout callbacks instead of logging in
+	r.HandleFunc("/api/v1/deposits/callbacks/{provider}", fundingHandler.Callback).Methods("POST")
+	r.HandleFunc("/api/v1/withdrawals/callbacks/{provider}", fundingHandler.PayoutCallback).Methods("POST")
+
+	// The public status page lists the money movements paused by a freeze
+	r.HandleFunc("/api/v1/status", freezeHandler.Status).Methods("GET")
+
+	// Credit-scoring partners read what users consented to with their own
+	// key rather than a session
+	partnerLimiter := app.RateLimiter("data-partners", 60, time.Minute)
+	r.Handle("/api/v1/partners/consents", partnerLimiter.Limit(http.HandlerFunc(dataSharingHandler.PartnerConsents))).Methods("GET")
+	r.Handle("/api/v1/partners/consents/{id}/summary", partnerLimiter.Limit(http.HandlerFunc(dataSharingHandler.PartnerSummary))).Methods("GET")
+
+	// Call-centre lookup for callers who cannot log in: rate limited per IP,
+	// locked per reference after repeated misses, and audited.
+	if sl := cfg.SupportLookup; sl.Enabled {
+		supportHandler := handler.NewSupportHandler(paymentService, redisClient, val, log, sl.MaxFailures, sl.Lockout)
+		supportLimiter := app.RateLimiter("support-lookup", sl.RatePerMinute, time.Minute).WithAdaptive(3, time.Hour)
+		r.Handle("/api/v1/support/lookup", supportLimiter.Limit(http.HandlerFunc(supportHandler.Lookup))).Methods("POST")
+	}
+
+	// Protected routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/auth/health", app.Health).Methods("GET")
+	api.HandleFunc("/payments/health", app.Health).Methods("GET")
+	api.HandleFunc("/wallets/health", app.Health).Methods("GET")
+	api.HandleFunc("/forex/health", app.Health).Methods("GET")
+	api.HandleFunc("/settlements/health", app.Health).Methods("GET")
+
+	api.Use(auditMW.Audit) // Audit logs for all API requests
+	api.Use(authMW.Authenticate)
+	api.Use(authz.Load)
+	api.Use(idemMW.Require) // Enforce Idempotency-Key
+	api.Use(apiLimiter.Limit)
+
+	api.HandleFunc("/home", dashboardHandler.Home).Methods("GET")
+	api.HandleFunc("/limits", limitsHandler.Get).Methods("GET")
+	api.HandleFunc("/dormancy", dormancyHandler.Status).Methods("GET")
+	api.HandleFunc("/dormancy/reactivate", dormancyHandler.RequestReactivation).Methods("POST")
+	api.HandleFunc("/data-sharing/partners", dataSharingHandler.ListPartners).Methods("GET")
+	api.HandleFunc("/data-sharing/consents", dataSharingHandler.ListConsents).Methods("GET")
+	api.HandleFunc("/data-sharing/consents", dataSharingHandler.GrantConsent).Methods("POST")
+	api.HandleFunc("/data-sharing/consents/{id}", dataSharingHandler.RevokeConsent).Methods("DELETE")
+	api.HandleFunc("/data-sharing/access-log", dataSharingHandler.AccessLog).Methods("GET")
+	api.HandleFunc("/credit-facilities", creditHandler.MyFacilities).Methods("GET")
+	api.HandleFunc("/complaints", complaintHandler.Submit).Methods("POST")
+	api.HandleFunc("/complaints", complaintHandler.ListMine).Methods("GET")
+	api.HandleFunc("/complaints/{id}", complaintHandler.GetMine).Methods("GET")
+	api.HandleFunc("/standing-instructions", standingHandler.List).Methods("GET")
+	api.HandleFunc("/standing-instructions", standingHandler.Create).Methods("POST")
+	api.HandleFunc("/standing-instructions/{id}", standingHandler.Update).Methods("PATCH")
+	api.HandleFunc("/standing-instructions/{id}", standingHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/standing-instructions/{id}/executions", standingHandler.Executions).Methods("GET")
+	api.HandleFunc("/pockets", standingHandler.Pockets).Methods("GET")
+	api.HandleFunc("/pockets", standingHandler.CreatePocket).Methods("POST")
+	api.HandleFunc("/pockets/{id}/withdraw", standingHandler.WithdrawFromPocket).Methods("POST")
+	// Ahead of the /payments routes, where it would be taken for an ID
+	api.HandleFunc("/payments/stream", txStreamHandler.Stream).Methods("GET")
+	api.HandleFunc("/limits/controls", paymentHandler.GetSpendingControls).Methods("GET")
+	api.HandleFunc("/limits/controls", paymentHandler.UpdateSpendingControls).Methods("PUT")
+	api.HandleFunc("/beneficiaries/trusted", paymentHandler.ListTrustedBeneficiaries).Methods("GET")
+	api.HandleFunc("/beneficiaries/trusted", paymentHandler.AddTrustedBeneficiary).Methods("POST")
+	api.HandleFunc("/beneficiaries/trusted/{id}", paymentHandler.RemoveTrustedBeneficiary).Methods("DELETE")
+	api.HandleFunc("/features", segmentsHandler.Features).Methods("GET")
+	api.HandleFunc("/plans", plansHandler.List).Methods("GET")
+	api.HandleFunc("/plans/me", plansHandler.Mine).Methods("GET")
+	api.HandleFunc("/plans/me", plansHandler.Change).Methods("POST")
+	api.HandleFunc("/billing/invoices", billingHandler.ListInvoices).Methods("GET")
+	api.HandleFunc("/billing/invoices/{id}", billingHandler.GetInvoice).Methods("GET")
+	api.HandleFunc("/billing/invoices/{id}/pay", billingHandler.PayInvoice).Methods("POST")
+	api.HandleFunc("/billing/usage", billingHandler.Usage).Methods("GET")
+	api.HandleFunc("/webhooks", webhooksHandler.List).Methods("GET")
+	api.HandleFunc("/webhooks", webhooksHandler.Create).Methods("POST")
+	api.HandleFunc("/webhooks/{id}", webhooksHandler.Get).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", webhooksHandler.Update).Methods("PATCH")
+	api.HandleFunc("/webhooks/{id}", webhooksHandler.Delete).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/rotate-secret", webhooksHandler.RotateSecret).Methods("POST")
+	api.HandleFunc("/webhooks/{id}/deliveries", webhooksHandler.Deliveries).Methods("GET")
+	api.HandleFunc("/webhooks/{id}/deliveries/{deliveryID}", webhooksHandler.Delivery).Methods("GET")
+	api.HandleFunc("/developer/keys", developerHandler.Keys).Methods("GET")
+	api.HandleFunc("/developer/keys/{id}/usage", developerHandler.KeyUsage).Methods("GET")
+	api.HandleFunc("/developer/keys/{id}/errors", developerHandler.KeyErrors).Methods("GET")
+	api.HandleFunc("/developer/webhooks", developerHandler.Webhooks).Methods("GET")
+	api.HandleFunc("/developer/environments", developerHandler.Environments).Methods("GET")
+	api.HandleFunc("/developer/environments", developerHandler.SetEnvironments).Methods("PUT")
+	api.HandleFunc("/developer/docs", developerHandler.Docs).Methods("GET")
+	api.HandleFunc("/webhooks/{id}/deliveries/{deliveryID}/replay", webhooksHandler.ReplayDelivery).Methods("POST")
+	api.HandleFunc("/webhooks/{id}/replay", webhooksHandler.Replay).Methods("POST")
+	api.HandleFunc("/webhooks/{id}/signature", webhooksHandler.Signature).Methods("POST")
+	api.HandleFunc("/webhooks/{id}/test", webhooksHandler.SendTest).Methods("POST")
+	api.HandleFunc("/reports/schedules", reportsHandler.ListSchedules).Methods("GET")
+	api.HandleFunc("/reports/schedules", reportsHandler.CreateSchedule).Methods("POST")
+	api.HandleFunc("/reports/schedules/{id}", reportsHandler.DeleteSchedule).Methods("DELETE")
+	api.HandleFunc("/reports", reportsHandler.List).Methods("GET")
+	api.HandleFunc("/reports", reportsHandler.Create).Methods("POST")
+	api.HandleFunc("/reports/{id}", reportsHandler.Get).Methods("GET")
+	api.HandleFunc("/statements/schedules", statementsHandler.ListSchedules).Methods("GET")
+	api.HandleFunc("/statements/schedules", statementsHandler.CreateSchedule).Methods("POST")
+	api.HandleFunc("/statements/schedules/{id}", statementsHandler.DeleteSchedule).Methods("DELETE")
+	api.HandleFunc("/statements/schedules/{id}/regenerate", statementsHandler.Regenerate).Methods("POST")
+	api.HandleFunc("/statements/deliveries", statementsHandler.ListDeliveries).Methods("GET")
+	api.HandleFunc("/statements/deliveries/{id}", statementsHandler.GetDelivery).Methods("GET")
+	api.HandleFunc("/statements/deliveries/{id}/file", statementsHandler.File).Methods("GET")
+	api.HandleFunc("/wallets", walletHandler.GetUserWallets).Methods("GET")
+	// Money movement is rejected during maintenance; reads stay available.
+	paymentMaintenance := middleware.RejectDuringMaintenance(maintenanceMode, maintenance.ServicePayment)
+	walletMaintenance := middleware.RejectDuringMaintenance(maintenanceMode, maintenance.ServiceWallet)
+	requireVerifiedEmail := middleware.RequireVerifiedEmail(auth.NewVerificationGate(userRepo, auth.VerificationPolicyFromConfig(cfg.Verification)))
+
+	api.Handle("/wallets", requireVerifiedEmail(http.HandlerFunc(walletHandler.CreateWallet))).Methods("POST")
+	api.HandleFunc("/wallets/lookup", walletHandler.LookupWallet).Methods("GET")
+	api.HandleFunc("/wallets/search", walletHandler.SearchWallets).Methods("GET")
+	api.HandleFunc("/wallets/qr/decode", walletHandler.DecodeQRCode).Methods("POST")
+	api.HandleFunc("/wallets/{id}/qr", walletHandler.GetQRCode).Methods("GET")
+	api.Handle("/wallets/{id}/deposit", walletMaintenance(http.HandlerFunc(fundingHandler.Deposit))).Methods("POST")
+	api.Handle("/wallets/{id}/withdraw", walletMaintenance(requireVerifiedEmail(http.HandlerFunc(fundingHandler.Withdraw)))).Methods("POST")
+	api.HandleFunc("/wallets/{id}/transactions", walletHandler.GetTransactionHistory).Methods("GET")
+	api.Handle("/payments", paymentMaintenance(requireVerifiedEmail(http.HandlerFunc(paymentHandler.InitiatePayment)))).Methods("POST")
+	api.Handle("/payments/initiate", paymentMaintenance(requireVerifiedEmail(http.HandlerFunc(paymentHandler.InitiatePayment)))).Methods("POST") // Add explicit route
+	api.HandleFunc("/payments", paymentHandler.GetTransactions).Methods("GET")
+	// Ahead of the /payments subrouter, where "batches", "disclosures",
+	// "fees" and "quote" would be taken for an ID
+	api.Handle("/payments/batches", paymentMaintenance(requireVerifiedEmail(http.HandlerFunc(paymentBatchHandler.Upload)))).Methods("POST")
+	api.HandleFunc("/payments/batches", paymentBatchHandler.List).Methods("GET")
+	api.HandleFunc("/payments/batches/{id}", paymentBatchHandler.Get).Methods("GET")
+	api.HandleFunc("/payments/batches/{id}/items", paymentBatchHandler.Items).Methods("GET")
+	api.HandleFunc("/payments/batches/{id}/report", paymentBatchHandler.Report).Methods("GET")
+	api.HandleFunc("/payments/disclosures", paymentHandler.CreateDisclosure).Methods("POST")
+	api.HandleFunc("/payments/disclosures/{id}", paymentHandler.GetDisclosure).Methods("GET")
+	api.HandleFunc("/payments/fees/quote", paymentHandler.QuoteFee).Methods("POST")
+	api.HandleFunc("/payments/quote", paymentHandler.CreateQuote).Methods("POST")
+	api.HandleFunc("/payments/{id}/disclosure", paymentHandler.GetTransactionDisclosureForUser).Methods("GET")
+	api.HandleFunc("/payments/{id}/timeline", paymentHandler.GetTimeline).Methods("GET")
+	// Ahead of /invoices/{id}, where "pay" would be taken for an ID
+	api.HandleFunc("/invoices/pay/{token}", invoiceHandler.View).Methods("GET")
+	api.Handle("/invoices/pay/{token}", paymentMaintenance(requireVerifiedEmail(http.HandlerFunc(invoiceHandler.Pay)))).Methods("POST")
+	api.HandleFunc("/invoices", invoiceHandler.Create).Methods("POST")
+	api.HandleFunc("/invoices", invoiceHandler.List).Methods("GET")
+	api.HandleFunc("/invoices/{id}", invoiceHandler.Get).Methods("GET")
+	api.HandleFunc("/invoices/{id}/send", invoiceHandler.Send).Methods("POST")
+	api.HandleFunc("/invoices/{id}/cancel", invoiceHandler.Cancel).Methods("POST")
+	api.HandleFunc("/payments/{id}/note", paymentHandler.GetNote).Methods("GET")
+	api.HandleFunc("/payments/{id}/note", paymentHandler.SetNote).Methods("PUT")
+	api.HandleFunc("/transactions/{id}/receipt", paymentHandler.GetReceipt).Methods("GET")
+	api.HandleFunc("/disputes", paymentHandler.InitiateDispute).Methods("POST")
+
+	// Compliance
+	api.HandleFunc("/compliance/kyc/submit", complianceHandler.SubmitKYC).Methods("POST")
+	api.HandleFunc("/compliance/kyc/status", complianceHandler.GetKYCStatus).Methods("GET")
+	api.HandleFunc("/compliance/kyc/documents/{id}/reupload", complianceHandler.ResubmitDocument).Methods("POST")
+
+	// Notifications
+	api.HandleFunc("/notifications", notificationHandler.List).Methods("GET")
+	api.HandleFunc("/notifications/unread-count", notificationHandler.UnreadCount).Methods("GET")
+	api.HandleFunc("/notifications/read-all", notificationHandler.MarkAllRead).Methods("POST")
+	api.HandleFunc("/notifications/push-tokens", notificationHandler.ListPushTokens).Methods("GET")
+	api.HandleFunc("/notifications/push-tokens", notificationHandler.RegisterPushToken).Methods("POST")
+	api.HandleFunc("/notifications/push-tokens/{id}", notificationHandler.UnregisterPushToken).Methods("DELETE")
+	api.HandleFunc("/notifications/preferences", notificationHandler.GetPreferences).Methods("GET")
+	api.HandleFunc("/notifications/preferences", notificationHandler.UpdatePreferences).Methods("PUT")
+	api.HandleFunc("/notifications/{id}/read", notificationHandler.MarkRead).Methods("POST")
+	api.HandleFunc("/notifications/{id}/{event:opened|clicked}", notificationHandler.Track).Methods("POST")
+	api.HandleFunc("/notifications/{id}", notificationHandler.Archive).Methods("DELETE")
+
+	// Static files for KYC Documents (Served via API to ensure Auth)
+	// Maps /api/v1/uploads/kyc/... to ./uploads/kyc/...
+	// Note: We use a raw handler here, wrapping it manually if needed,
+	// but since it's on 'api' subrouter, it inherits authMW.
+	api.PathPrefix("/uploads/kyc/").Handler(
+		http.StripPrefix("/api/v1/uploads/kyc/", http.FileServer(http.Dir("./uploads/kyc"))),
+	).Methods("GET")
+
+	// Forex routes
+	api.HandleFunc("/forex/rates", forexHandler.GetAllRates).Methods("GET")
+	api.HandleFunc("/forex/history", forexHandler.GetHistory).Methods("GET")
+	api.HandleFunc("/forex/ohlc", forexHandler.GetOHLC).Methods("GET")
+	api.HandleFunc("/forex/calculate", forexHandler.Calculate).Methods("POST")
+
+	// Admin routes
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(app.RateLimiter("payment-admin", 60, time.Minute).WithAdaptive(5, 15*time.Minute).Limit)
+	// Each group of admin endpoints needs its own permission (see rbac.AdminPermission)
+	admin.Use(authz.Guard(rbac.AdminPermission))
+
+	// Admin: Dashboard
+	admin.HandleFunc("/dashboard", dashboardHandler.Overview).Methods("GET")
+
+	// Admin: User Management
+	admin.HandleFunc("/users", usersHandler.List).Methods("GET")
+	admin.HandleFunc("/users/{id}", usersHandler.Get).Methods("GET")
+	admin.HandleFunc("/users/{id}", usersHandler.Update).Methods("PATCH")
+	admin.HandleFunc("/users/{id}", usersHandler.DeleteUser).Methods("DELETE")
+	admin.HandleFunc("/users/{id}/block", usersHandler.BlockUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/plan", plansHandler.Assign).Methods("PUT")
+	admin.HandleFunc("/billing/invoices", billingHandler.AdminListInvoices).Methods("GET")
+	admin.HandleFunc("/billing/invoices/{id}/paid", billingHandler.MarkInvoicePaid).Methods("POST")
+	admin.HandleFunc("/webhooks", webhooksHandler.AdminList).Methods("GET")
+	admin.HandleFunc("/webhooks", webhooksHandler.AdminCreate).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}", webhooksHandler.AdminUpdate).Methods("PATCH")
+	admin.HandleFunc("/webhooks/{id}", webhooksHandler.AdminDelete).Methods("DELETE")
+	admin.HandleFunc("/webhooks/{id}/rotate-secret", webhooksHandler.AdminRotateSecret).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}/deliveries", webhooksHandler.AdminDeliveries).Methods("GET")
+	admin.HandleFunc("/webhooks/{id}/deliveries/{deliveryID}", webhooksHandler.AdminDelivery).Methods("GET")
+	admin.HandleFunc("/webhooks/{id}/deliveries/{deliveryID}/replay", webhooksHandler.AdminReplayDelivery).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}/replay", webhooksHandler.AdminReplay).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}/signature", webhooksHandler.AdminSignature).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}/test", webhooksHandler.AdminSendTest).Methods("POST")
+	admin.HandleFunc("/users/{id}/unblock", usersHandler.UnblockUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/activity", usersHandler.GetActivity).Methods("GET")
+	admin.HandleFunc("/users/{id}/overview", usersHandler.GetOverview).Methods("GET")
+	admin.HandleFunc("/users/{id}/state", usersHandler.GetStateAt).Methods("GET")
+	admin.HandleFunc("/unmask", usersHandler.Unmask).Methods("POST")
+
+	// Admin: Staff roles
+	admin.HandleFunc("/roles", rolesHandler.List).Methods("GET")
+	admin.HandleFunc("/permissions", rolesHandler.Mine).Methods("GET")
+	admin.HandleFunc("/users/{id}/roles", rolesHandler.UserRoles).Methods("GET")
+	admin.HandleFunc("/users/{id}/roles/{role}", rolesHandler.Assign).Methods("PUT")
+	admin.HandleFunc("/users/{id}/roles/{role}", rolesHandler.Revoke).Methods("DELETE")
+
+	// Admin: Analytics
+	admin.HandleFunc("/analytics/metrics", paymentHandler.GetSystemStats).Methods("GET")
+	admin.HandleFunc("/analytics/earnings", analyticsHandler.GetEarningsReport).Methods("GET")
+	admin.HandleFunc("/analytics/volume", paymentHandler.GetTransactionVolume).Methods("GET")
+	admin.HandleFunc("/analytics/funnel", analyticsHandler.GetFunnel).Methods("GET")
+	admin.HandleFunc("/analytics/retention", analyticsHandler.GetRetention).Methods("GET")
+	admin.HandleFunc("/analytics/rebuild", analyticsHandler.RebuildAggregates).Methods("POST")
+
+	// Admin: API Keys
+	admin.HandleFunc("/api-keys", apiKeyHandler.ListAPIKeys).Methods("GET")
+	admin.HandleFunc("/api-keys", apiKeyHandler.CreateAPIKey).Methods("POST")
+	admin.HandleFunc("/api-keys/{id}", apiKeyHandler.RevokeAPIKey).Methods("DELETE")
+
+	// Admin: Compliance
+	admin.HandleFunc("/compliance/applications", complianceHandler.ListApplications).Methods("GET")
+	admin.HandleFunc("/compliance/applications/{id}/review", complianceHandler.ReviewApplication).Methods("POST")
+	admin.HandleFunc("/compliance/kyc", complianceHandler.ListApplications).Methods("GET")
+	admin.HandleFunc("/compliance/kyc/{id}", complianceHandler.ReviewApplication).Methods("PATCH")
+	admin.HandleFunc("/compliance/documents/{id}/rescan", complianceHandler.RescanDocument).Methods("POST")
+	admin.HandleFunc("/compliance/users/{id}/screening", complianceHandler.GetScreening).Methods("GET")
+	admin.HandleFunc("/compliance/users/{id}/screening", complianceHandler.ScreenUser).Methods("POST")
+	admin.HandleFunc("/compliance/users/{id}/screening/clear", complianceHandler.ClearScreening).Methods("POST")
+	admin.HandleFunc("/compliance/reports", complianceHandler.GetComplianceReports).Methods("GET")
+	// Manual KYC review queue
+	admin.HandleFunc("/kyc", complianceHandler.ListReviewQueue).Methods("GET")
+	admin.HandleFunc("/kyc/{id}", complianceHandler.GetReviewProfile).Methods("GET")
+	admin.HandleFunc("/kyc/{id}/decision", complianceHandler.DecideProfile).Methods("POST")
+
+	// Admin: Transaction Management
+	admin.HandleFunc("/transactions", paymentHandler.GetAllTransactions).Methods("GET")
+	admin.HandleFunc("/transactions/pending", paymentHandler.GetPendingTransactions).Methods("GET")
+	admin.HandleFunc("/transactions/by-reference/{reference}", paymentHandler.GetTransactionByReference).Methods("GET")
+	admin.HandleFunc("/transactions/exceptions", recoveryHandler.ListExceptions).Methods("GET")
+	admin.HandleFunc("/transactions/exceptions/{id}/resolve", recoveryHandler.ResolveException).Methods("POST")
+	admin.HandleFunc("/transactions/recovery", recoveryHandler.Run).Methods("POST")
+	admin.HandleFunc("/dormancy/accounts", dormancyHandler.ListAccounts).Methods("GET")
+	admin.HandleFunc("/dormancy/accounts/{user_id}/reactivate", dormancyHandler.Reactivate).Methods("POST")
+	admin.HandleFunc("/dormancy/escheatments", dormancyHandler.Escheatments).Methods("GET")
+	admin.HandleFunc("/dormancy/runs", dormancyHandler.Run).Methods("POST")
+	admin.HandleFunc("/data-partners", dataSharingHandler.ListPartnersAdmin).Methods("GET")
+	admin.HandleFunc("/data-partners", dataSharingHandler.CreatePartner).Methods("POST")
+	admin.HandleFunc("/data-partners/{id}/status", dataSharingHandler.SetPartnerStatus).Methods("POST")
+	admin.HandleFunc("/credit-facilities", creditHandler.List).Methods("GET")
+	admin.HandleFunc("/credit-facilities", creditHandler.Open).Methods("POST")
+	admin.HandleFunc("/credit-facilities/report", creditHandler.Report).Methods("GET")
+	admin.HandleFunc("/credit-facilities/runs", creditHandler.Run).Methods("POST")
+	admin.HandleFunc("/credit-facilities/{id}", creditHandler.Update).Methods("PATCH")
+	admin.HandleFunc("/standing-instructions/runs", standingHandler.Run).Methods("POST")
+	admin.HandleFunc("/transactions/{id}", paymentHandler.GetTransaction).Methods("GET")
+	admin.HandleFunc("/transactions/{id}/review", paymentHandler.ReviewTransaction).Methods("POST")
+	admin.HandleFunc("/transactions/{id}/flag", paymentHandler.FlagTransaction).Methods("POST")
+	admin.HandleFunc("/transactions/{id}/reverse", paymentHandler.ReverseTransaction).Methods("POST")
+	admin.HandleFunc("/transactions/{id}/notes", paymentHandler.ListInternalNotes).Methods("GET")
+	admin.HandleFunc("/transactions/{id}/notes", paymentHandler.AddInternalNote).Methods("POST")
+	admin.HandleFunc("/transactions/{id}/disclosure", paymentHandler.GetTransactionDisclosure).Methods("GET")
+	admin.HandleFunc("/transactions/{id}/fx-rate", forexHandler.TransactionRate).Methods("GET")
+	admin.HandleFunc("/withdrawals", fundingHandler.ListWithdrawals).Methods("GET")
+	admin.HandleFunc("/withdrawals/{id}/review", fundingHandler.ReviewWithdrawal).Methods("POST")
+
+	// Admin: Risk & Disputes
+	admin.HandleFunc("/risk/alerts", paymentHandler.GetRiskAlerts).Methods("GET")
+	admin.HandleFunc("/risk/metrics", paymentHandler.GetRiskUsageMetrics).Methods("GET")
+	admin.HandleFunc("/risk/rules", riskBacktestHandler.Rules).Methods("GET")
+	admin.HandleFunc("/risk/backtests", riskBacktestHandler.List).Methods("GET")
+	admin.HandleFunc("/risk/backtests", riskBacktestHandler.Create).Methods("POST")
+	admin.HandleFunc("/risk/backtests/{id}", riskBacktestHandler.Get).Methods("GET")
+	admin.HandleFunc("/disputes", paymentHandler.GetDisputes).Methods("GET")
+	admin.HandleFunc("/disputes/resolve", paymentHandler.ResolveDispute).Methods("POST")
+
+	// Admin: Complaints register
+	admin.HandleFunc("/complaints", complaintHandler.List).Methods("GET")
+	admin.HandleFunc("/complaints", complaintHandler.Log).Methods("POST")
+	admin.HandleFunc("/complaints/returns", complaintHandler.ListReturns).Methods("GET")
+	admin.HandleFunc("/complaints/returns/{period}", complaintHandler.DownloadReturn).Methods("GET")
+	admin.HandleFunc("/complaints/{id}", complaintHandler.Get).Methods("GET")
+	admin.HandleFunc("/complaints/{id}/acknowledge", complaintHandler.Acknowledge).Methods("POST")
+	admin.HandleFunc("/complaints/{id}/resolve", complaintHandler.Resolve).Methods("POST")
+	admin.HandleFunc("/jurisdictions", jurisdictionHandler.List).Methods("GET")
+	admin.HandleFunc("/jurisdictions", jurisdictionHandler.Create).Methods("POST")
+	admin.HandleFunc("/jurisdictions/changes", jurisdictionHandler.Changes).Methods("GET")
+	admin.HandleFunc("/jurisdictions/{id}", jurisdictionHandler.Get).Methods("GET")
+	admin.HandleFunc("/jurisdictions/{id}", jurisdictionHandler.Update).Methods("PUT")
+	admin.HandleFunc("/jurisdictions/{id}", jurisdictionHandler.Delete).Methods("DELETE")
+
+	// Admin: System & Security
+	admin.HandleFunc("/system/status", systemHandler.GetSystemStatus).Methods("GET")
+	admin.HandleFunc("/system/queries", systemHandler.GetQueryStats).Methods("GET")
+	admin.HandleFunc("/system/forex/providers", forexHandler.ProviderStatus).Methods("GET")
+	admin.HandleFunc("/system/maintenance", maintenanceHandler.List).Methods("GET")
+	admin.HandleFunc("/system/maintenance/{scope}", maintenanceHandler.Enable).Methods("PUT")
+	admin.HandleFunc("/system/maintenance/{scope}", maintenanceHandler.Disable).Methods("DELETE")
+	admin.HandleFunc("/system/freezes", freezeHandler.List).Methods("GET")
+	admin.HandleFunc("/system/freezes", freezeHandler.Request).Methods("POST")
+	admin.HandleFunc("/system/freezes/{id}", freezeHandler.Get).Methods("GET")
+	admin.HandleFunc("/system/freezes/{id}/lift", freezeHandler.RequestLift).Methods("POST")
+	admin.HandleFunc("/system/freezes/{id}/approve", freezeHandler.Approve).Methods("POST")
+	admin.HandleFunc("/system/freezes/{id}/reject", freezeHandler.Reject).Methods("POST")
+	admin.HandleFunc("/system/integrity/findings", integrityHandler.ListFindings).Methods("GET")
+	admin.HandleFunc("/system/integrity/runs", integrityHandler.ListRuns).Methods("GET")
+	admin.HandleFunc("/system/integrity/runs", integrityHandler.Run).Methods("POST")
+	admin.HandleFunc("/system/workers", workersHandler.List).Methods("GET")
+	admin.HandleFunc("/system/outbox", outboxHandler.List).Methods("GET")
+	admin.HandleFunc("/audit-logs", systemHandler.GetAuditLogs).Methods("GET")
+	admin.HandleFunc("/audit/logs", paymentHandler.GetAuditLogs).Methods("GET")
+	admin.HandleFunc("/bulk-files", bulkFilesHandler.List).Methods("GET")
+	admin.HandleFunc("/bulk-files/{id}", bulkFilesHandler.Get).Methods("GET")
+	admin.HandleFunc("/bulk-files/{id}/content", bulkFilesHandler.Content).Methods("GET")
+	admin.HandleFunc("/exports", exportHandler.List).Methods("GET")
+	admin.HandleFunc("/exports", exportHandler.Create).Methods("POST")
+	admin.HandleFunc("/exports/{id}", exportHandler.Get).Methods("GET")
+	admin.HandleFunc("/report-schedules", reportsHandler.AdminListSchedules).Methods("GET")
+	admin.HandleFunc("/report-schedules/{id}", reportsHandler.AdminDeleteSchedule).Methods("DELETE")
+	admin.HandleFunc("/statements/schedules", statementsHandler.AdminListSchedules).Methods("GET")
+	admin.HandleFunc("/statements/schedules/{id}", statementsHandler.AdminDeleteSchedule).Methods("DELETE")
+	admin.HandleFunc("/statements/schedules/{id}/regenerate", statementsHandler.AdminRegenerate).Methods("POST")
+	admin.HandleFunc("/statements/deliveries", statementsHandler.AdminListDeliveries).Methods("GET")
+	admin.HandleFunc("/statements/deliveries/{id}", statementsHandler.AdminGetDelivery).Methods("GET")
+	admin.HandleFunc("/statements/deliveries/{id}/file", statementsHandler.AdminFile).Methods("GET")
+	admin.HandleFunc("/security/events", securityHandler.GetSecurityEvents).Methods("GET")
+	admin.HandleFunc("/security/events/{id}", securityHandler.UpdateSecurityEvent).Methods("PATCH")
+	admin.HandleFunc("/cases", casesHandler.List).Methods("GET")
+	admin.HandleFunc("/cases", casesHandler.Create).Methods("POST")
+	admin.HandleFunc("/cases/{id}", casesHandler.Get).Methods("GET")
+	admin.HandleFunc("/cases/{id}", casesHandler.Update).Methods("PATCH")
+	admin.HandleFunc("/cases/{id}/events", casesHandler.ListEvents).Methods("GET")
+	admin.HandleFunc("/broadcasts", broadcastHandler.List).Methods("GET")
+	admin.HandleFunc("/broadcasts", broadcastHandler.Create).Methods("POST")
+	admin.HandleFunc("/broadcasts/preview", broadcastHandler.Preview).Methods("POST")
+	admin.HandleFunc("/broadcasts/{id}", broadcastHandler.Get).Methods("GET")
+	admin.HandleFunc("/broadcasts/{id}/cancel", broadcastHandler.Cancel).Methods("POST")
+	admin.HandleFunc("/notifications/experiments", notificationHandler.ListExperiments).Methods("GET")
+	admin.HandleFunc("/notifications/experiments", notificationHandler.CreateExperiment).Methods("POST")
+	admin.HandleFunc("/notifications/experiments/{id}/results", notificationHandler.ExperimentResults).Methods("GET")
+	admin.HandleFunc("/notifications/experiments/{id}/stop", notificationHandler.StopExperiment).Methods("POST")
+	admin.HandleFunc("/segments", segmentsHandler.List).Methods("GET")
+	admin.HandleFunc("/segments", segmentsHandler.Create).Methods("POST")
+	admin.HandleFunc("/segments/preview", segmentsHandler.Preview).Methods("POST")
+	admin.HandleFunc("/segments/{id}", segmentsHandler.Get).Methods("GET")
+	admin.HandleFunc("/segments/{id}", segmentsHandler.Update).Methods("PUT")
+	admin.HandleFunc("/segments/{id}", segmentsHandler.Delete).Methods("DELETE")
+	admin.HandleFunc("/feature-flags", segmentsHandler.ListFlags).Methods("GET")
+	admin.HandleFunc("/feature-flags/{key}", segmentsHandler.SetFlag).Methods("PUT")
+	admin.HandleFunc("/feature-flags/{key}", segmentsHandler.DeleteFlag).Methods("DELETE")
+	admin.HandleFunc("/fee-schedules", feeScheduleHandler.List).Methods("GET")
+	admin.HandleFunc("/fee-schedules", feeScheduleHandler.Create).Methods("POST")
+	admin.HandleFunc("/fee-schedules/{id}", feeScheduleHandler.Get).Methods("GET")
+	admin.HandleFunc("/fee-schedules/{id}", feeScheduleHandler.Update).Methods("PUT")
+	admin.HandleFunc("/fee-schedules/{id}", feeScheduleHandler.Delete).Methods("DELETE")
+	admin.HandleFunc("/fee-schedules/{id}/end", feeScheduleHandler.End).Methods("POST")
+	admin.HandleFunc("/fx-spreads", fxSpreadHandler.List).Methods("GET")
+	admin.HandleFunc("/fx-spreads/{from}/{to}", fxSpreadHandler.Set).Methods("PUT")
+	admin.HandleFunc("/fx-spreads/{from}/{to}", fxSpreadHandler.Delete).Methods("DELETE")
+	admin.HandleFunc("/fx-revenue", fxSpreadHandler.Revenue).Methods("GET")
+	admin.HandleFunc("/security/blocklist", securityHandler.GetBlocklist).Methods("GET")
+	admin.HandleFunc("/security/blocklist", securityHandler.AddToBlocklist).Methods("POST")
+	admin.HandleFunc("/security/blocklist/{id}", securityHandler.RemoveFromBlocklist).Methods("DELETE")
+	admin.HandleFunc("/security/health", securityHandler.GetSystemHealth).Methods("GET")
+	admin.HandleFunc("/notifications", systemHandler.GetNotifications).Methods("GET")
+	admin.HandleFunc("/notifications/read-all", systemHandler.MarkAllNotificationsRead).Methods("POST")
+	admin.HandleFunc("/notifications/{id}/read", systemHandler.MarkNotificationRead).Methods("POST")
+	admin.HandleFunc("/notifications/{id}", systemHandler.ArchiveNotification).Methods("DELETE")
+
+	// Admin: Wallets
+	admin.HandleFunc("/wallets", walletHandler.GetAllWallets).Methods("GET")
+	admin.HandleFunc("/wallets/fix-addresses", walletHandler.FixWalletAddresses).Methods("POST")
+	admin.HandleFunc("/wallets/{id}/transactions", walletHandler.GetTransactionHistoryAdmin).Methods("GET")
+	admin.HandleFunc("/blockchain/wallets", walletHandler.GetBlockchainWallets).Methods("GET")
+
+	// Admin: Blockchain Network Management
+	admin.HandleFunc("/blockchain/networks", blockchainHandler.ListNetworks).Methods("GET")
+	admin.HandleFunc("/blockchain/networks", blockchainHandler.CreateNetwork).Methods("POST")
+	admin.HandleFunc("/blockchain/networks/{id}", blockchainHandler.GetNetwork).Methods("GET")
+	admin.HandleFunc("/blockchain/networks/{id}", blockchainHandler.UpdateNetwork).Methods("PUT", "PATCH")
+	admin.HandleFunc("/blockchain/networks/{id}", blockchainHandler.DeleteNetwork).Methods("DELETE")
+	admin.HandleFunc("/blockchain/wallets/{wallet_id}/verify-ledger", blockchainHandler.VerifyLedgerChain).Methods("GET")
+	admin.HandleFunc("/blockchain/wallets/{wallet_id}/ledger-chain", blockchainHandler.GetLedgerChainReport).Methods("GET")
+
+	// Admin: General ledger
+	admin.HandleFunc("/ledger/accounts", ledgerHandler.ListAccounts).Methods("GET")
+	admin.HandleFunc("/ledger/trial-balance", ledgerHandler.TrialBalance).Methods("GET")
+	admin.HandleFunc("/reconciliation/breaks", reconciliationHandler.ListBreaks).Methods("GET")
+	admin.HandleFunc("/reconciliation/runs", reconciliationHandler.Run).Methods("POST")
+
+	// Admin: Banking
+	admin.HandleFunc("/banking/settlements", settlementHandler.ListSettlements).Methods("GET")
+	admin.HandleFunc("/banking/settlements/fees", settlementHandler.GetNetworkFeeReport).Methods("GET")
+	admin.HandleFunc("/banking/settlements/throttle", settlementHandler.GetThrottleStatus).Methods("GET")
+	admin.HandleFunc("/banking/settlements/{id}", settlementHandler.GetSettlement).Methods("GET")
+	admin.HandleFunc("/banking/settlements/{id}/netting", settlementHandler.GetSettlementNetting).Methods("GET")
+	admin.HandleFunc("/banking/settlements/{id}/retry", settlementHandler.RetrySettlement).Methods("POST")
+	admin.HandleFunc("/banking/settlements/{id}/reconcile", settlementHandler.ReconcileSettlement).Methods("POST")
+	admin.HandleFunc("/banking/settlement-routes", settlementRouteHandler.List).Methods("GET")
+	admin.HandleFunc("/banking/settlement-routes", settlementRouteHandler.Create).Methods("POST")
+	admin.HandleFunc("/banking/settlement-routes/resolve", settlementRouteHandler.Resolve).Methods("GET")
+	admin.HandleFunc("/banking/settlement-routes/simulate", settlementRouteHandler.Simulate).Methods("POST")
+	admin.HandleFunc("/banking/settlement-routes/{id}", settlementRouteHandler.Get).Methods("GET")
+	admin.HandleFunc("/banking/settlement-routes/{id}", settlementRouteHandler.Update).Methods("PUT")
+	admin.HandleFunc("/banking/settlement-routes/{id}", settlementRouteHandler.Delete).Methods("DELETE")
+	admin.HandleFunc("/banking/counterparties", counterpartiesHandler.List).Methods("GET")
+	admin.HandleFunc("/banking/counterparties", counterpartiesHandler.Create).Methods("POST")
+	admin.HandleFunc("/banking/counterparties/exposure", counterpartiesHandler.Exposure).Methods("GET")
+	admin.HandleFunc("/banking/counterparties/{id}", counterpartiesHandler.Get).Methods("GET")
+	admin.HandleFunc("/banking/counterparties/{id}", counterpartiesHandler.Update).Methods("PUT")
+	admin.HandleFunc("/banking/counterparties/{id}", counterpartiesHandler.Delete).Methods("DELETE")
+	admin.HandleFunc("/banking/counterparties/{id}/status", counterpartiesHandler.SetStatus).Methods("POST")
+	admin.HandleFunc("/banking/counterparties/{id}/documents", counterpartiesHandler.ListDocuments).Methods("GET")
+	admin.HandleFunc("/banking/counterparties/{id}/documents", counterpartiesHandler.AddDocument).Methods("POST")
+	admin.HandleFunc("/banking/counterparties/{id}/documents/{doc_id}", counterpartiesHandler.DeleteDocument).Methods("DELETE")
+	admin.HandleFunc("/banking/counterparties/{id}/prefunding", counterpartiesHandler.ListPrefunding).Methods("GET")
+	admin.HandleFunc("/banking/counterparties/{id}/prefunding", counterpartiesHandler.Prefund).Methods("POST")
+	admin.HandleFunc("/banking/accounts", settlementHandler.GetBankAccounts).Methods("GET")
+	admin.HandleFunc("/banking/gateways", settlementHandler.GetPaymentGateways).Methods("GET")
+
+	// Cleaned up redundant code blocks
+
+	registerPaymentRoutes(api.PathPrefix("/payments").Subrouter(), paymentHandler, paymentMaintenance, requireVerifiedEmail)
+
+	return r, nil
+}
+
+// registerPaymentRoutes mounts the /payments subrouter. Every route that
+// sends money is refused during maintenance and to users whose email
+// verification grace period has ended.
+func registerPaymentRoutes(payments *mux.Router, h *handler.PaymentHandler, maintenance, verifiedEmail func(http.Handler) http.Handler) {
+	send := func(fn http.HandlerFunc) http.Handler {
+		return maintenance(verifiedEmail(fn))
+	}
+	// payments.Use(idemMW.Require) - Removed redundant middleware (already on api)
+	payments.Handle("/initiate", send(h.InitiatePayment)).Methods("POST")
+	// payments.HandleFunc("/receiver-info", paymentHandler.GetReceiverInfo).Methods("GET") // Removed, use /wallets/lookup or /wallets/search
+	payments.HandleFunc("/by-reference/{reference}", h.GetTransactionByReference).Methods("GET")
+	payments.Handle("/escrow", send(h.CreateEscrow)).Methods("POST")
+	payments.HandleFunc("/escrow", h.ListEscrows).Methods("GET")
+	payments.Handle("/escrow/{id}/release", send(h.ReleaseEscrow)).Methods("POST")
+	payments.Handle("/escrow/{id}/refund", maintenance(http.HandlerFunc(h.RefundEscrow))).Methods("POST")
+	payments.HandleFunc("/{id}/receipt", h.GetReceipt).Methods("GET")
+	payments.HandleFunc("/{id}", h.GetTransactionForUser).Methods("GET")
+	payments.Handle("/{id}/cancel", maintenance(http.HandlerFunc(h.CancelPayment))).Methods("POST")
+	payments.Handle("/bulk", send(h.BulkPayment)).Methods("POST")
+	payments.HandleFunc("", h.GetTransactions).Methods("GET")
+}
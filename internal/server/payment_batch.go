@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	"kyd/internal/paymentbatch"
+)
+
+// batchPayer pays the items of payment batches through the payment
+// service, each as if the sender had made it on its own.
+type batchPayer struct {
+	payments *payment.Service
+}
+
+func (p batchPayer) Pay(ctx context.Context, b *paymentbatch.Payment) (*domain.Transaction, error) {
+	resp, err := p.payments.InitiatePayment(ctx, &payment.InitiatePaymentRequest{
+		SenderID:            b.SenderID,
+		ReceiverID:          b.ReceiverID,
+		Amount:              b.Amount,
+		Currency:            b.Currency,
+		DestinationCurrency: b.DestinationCurrency,
+		Description:         b.Description,
+		Channel:             "batch",
+		Reference:           b.Reference,
+		Metadata:            b.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Transaction, nil
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kyd/internal/handler"
+	"kyd/internal/middleware"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unverifiedEmail struct{}
+
+func (unverifiedEmail) CheckEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	return kyderrors.ErrEmailNotVerified
+}
+
+func TestPaymentRoutes_SendingMoneyRequiresVerifiedEmail(t *testing.T) {
+	const secret = "test-secret"
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware.NewAuthMiddleware(secret, nil).Authenticate)
+	none := func(next http.Handler) http.Handler { return next }
+	ph := handler.NewPaymentHandler(nil, nil, logger.NewNop())
+	registerPaymentRoutes(api.PathPrefix("/payments").Subrouter(), ph, none, middleware.RequireVerifiedEmail(unverifiedEmail{}))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":   uuid.New().String(),
+		"user_type": "individual",
+	}).SignedString([]byte(secret))
+	require.NoError(t, err)
+
+	for _, path := range []string{"/api/v1/payments/bulk", "/api/v1/payments/initiate", "/api/v1/payments/escrow", "/api/v1/payments/escrow/" + uuid.NewString() + "/release"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), "email_verification_required", path)
+	}
+}
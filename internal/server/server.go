@@ -0,0 +1,58 @@
+// Package server wires each service's repositories, services and routes onto
+// a bootstrap.App. The per-service mains under cmd/ serve one of these
+// handlers on its own listener; cmd/all mounts several behind the in-process
+// gateway.
+package server
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/rbac"
+	"kyd/internal/repository/postgres"
+	"kyd/pkg/bootstrap"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// userStatusChecker lets the auth middleware reject tokens of blocked users.
+type userStatusChecker struct {
+	repo *postgres.UserRepository
+	log  logger.Logger
+	// adminsActive keeps admins in even when their account is deactivated,
+	// so they can still reach the admin API to undo it.
+	adminsActive bool
+}
+
+func (c *userStatusChecker) IsUserActive(ctx context.Context, id uuid.UUID) (bool, error) {
+	u, err := c.repo.FindByID(ctx, id)
+	if err != nil {
+		if c.log != nil {
+			c.log.Error("IsUserActive: FindByID failed", map[string]interface{}{
+				"user_id": id.String(),
+				"error":   err.Error(),
+			})
+		}
+		return false, err
+	}
+	if c.adminsActive && u.UserType == domain.UserTypeAdmin {
+		return true, nil
+	}
+	return u.IsActive, nil
+}
+
+// UserType returns the type of the account an API key acts for.
+func (c *userStatusChecker) UserType(ctx context.Context, id uuid.UUID) (string, error) {
+	u, err := c.repo.FindByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return string(u.UserType), nil
+}
+
+// newRoles builds the staff role service. Each service keeps its own cache
+// of staff permissions.
+func newRoles(app *bootstrap.App, users rbac.UserRepository) *rbac.Service {
+	return rbac.NewService(postgres.NewRBACRepository(app.DB), users, app.Config.RBAC, app.Logger)
+}
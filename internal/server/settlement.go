@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"kyd/internal/blockchain"
+	"kyd/internal/blockchain/deposit"
+	"kyd/internal/blockchain/ripple"
+	"kyd/internal/blockchain/stellar"
+	"kyd/internal/counterparty"
+	"kyd/internal/domain"
+	"kyd/internal/heartbeat"
+	"kyd/internal/freeze"
+	"kyd/internal/jurisdiction"
+	"kyd/internal/maintenance"
+	"kyd/internal/middleware"
+	"kyd/internal/repository/postgres"
+	"kyd/internal/security"
+	"kyd/internal/settlement"
+	"kyd/internal/txstream"
+	"kyd/internal/wallet"
+	"kyd/internal/webhook"
+	"kyd/pkg/bootstrap"
+	"kyd/pkg/errors"
+	"kyd/pkg/metrics"
+)
+
+// Settlement builds the settlement service's router and starts the inbound
+// deposit listener, which runs until the app shuts down. app must be
+// connected to the database and Redis.
+func Settlement(app *bootstrap.App) (http.Handler, error) {
+	cfg, log := app.Config, app.Logger
+	db, redisClient := app.DB, app.Redis
+
+	// Initialize blockchain connectors
+	stellarConnector, err := stellar.NewConnector(
+		"", // force local-only connector (no external network)
+		cfg.Stellar.SecretKey,
+		true, // always simulate locally
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Stellar connector")
+	}
+
+	rippleConnector, err := ripple.NewConnector(
+		"", // force local-only connector (no external network)
+		cfg.Ripple.SecretKey,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Ripple connector")
+	}
+
+	log.Info("Blockchain connectors initialized", nil)
+
+	// Initialize security service
+	cryptoService, err := security.NewCryptoService()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize crypto service")
+	}
+
+	// Initialize repositories
+	settlementRepo := postgres.NewSettlementRepository(db)
+	txRepo := postgres.NewTransactionRepository(db)
+	userRepo := postgres.NewUserRepository(db, cryptoService)
+	walletRepo := postgres.NewWalletRepository(db)
+	depositRepo := postgres.NewOnchainDepositRepository(db)
+	if cfg.Outbox.Enabled {
+		txRepo.WithOutbox()
+		settlementRepo.WithOutbox()
+	}
+	newActivityProjector(cfg.Activity, app, cryptoService, txRepo, userRepo, walletRepo, log)
+	blockchainService := blockchain.NewService(postgres.NewBlockchainNetworkRepository(db))
+	metrics.RegisterSettlementQueue(func(ctx context.Context) (int, error) {
+		return txRepo.CountByStatus(ctx, domain.TransactionStatusPendingSettlement)
+	})
+
+	// Settlement events fire webhooks too, and reach the parties' open
+	// transaction streams; the payment service delivers both
+	webhookEvents := txstream.NewStream(
+		webhook.NewService(postgres.NewWebhookRepository(db), txRepo, webhook.ConfigFromConfig(cfg.Webhook), log).
+			Stream(postgres.NewTransactionEventRepository(db)),
+		txRepo, txstream.NewRedisBus(redisClient), log)
+
+	// Initialize settlement service
+	corridorModes, err := settlement.ParseCorridorModes(cfg.Settlement.CorridorModes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SETTLEMENT_CORRIDOR_MODES")
+	}
+	settlementService := settlement.NewService(
+		settlementRepo,
+		txRepo,
+		stellarConnector,
+		rippleConnector,
+		log,
+	).WithFeePolicy(settlement.FeePolicyFromConfig(cfg.Settlement)).
+		WithThrottle(settlement.NewThrottle(settlement.ThrottleConfigFromConfig(cfg.Settlement), blockchainService)).
+		WithRouter(settlement.NewRouter(postgres.NewSettlementRouteRepository(db), domain.NetworkStellar, domain.NetworkRipple)).
+		WithCorridorModes(corridorModes, postgres.NewSettlementNettingRepository(db)).
+		WithCounterparties(counterparty.NewService(postgres.NewCounterpartyRepository(db), log).WithRates(postgres.NewForexRepository(db))).
+		WithEventStream(webhookEvents).
+		WithMaintenance(maintenance.NewMode(maintenance.NewRedisStore(redisClient), cfg.Maintenance)).
+		WithFreezes(freeze.NewService(postgres.NewFreezeRepository(db), cfg.Freeze, log)).
+		WithJurisdictions(jurisdiction.NewService(postgres.NewJurisdictionRepository(db), cfg.Jurisdiction, log))
+
+	// Inbound deposit listener
+	walletService := wallet.NewService(walletRepo, txRepo, userRepo, log)
+	depositListener := deposit.NewListener(depositRepo, walletRepo, walletService, log)
+	depositListener.Watch(domain.NetworkStellar, stellarConnector, cfg.Stellar.DepositAddresses, cfg.Stellar.DepositConfirmations)
+	depositListener.Watch(domain.NetworkRipple, rippleConnector, cfg.Ripple.DepositAddresses, cfg.Ripple.DepositConfirmations)
+	// The listener's scan cursors live in memory, so a stalled listener is
+	// alerted on rather than restarted.
+	heartbeats := heartbeat.NewMonitor(postgres.NewHeartbeatRepository(db), "settlement", cfg.Heartbeat, log)
+	app.Start(heartbeats)
+	depositListener.WithHeartbeat(heartbeats.Track("deposit_listener", cfg.Deposit.PollInterval))
+	go depositListener.Start(app.Context(), cfg.Deposit.PollInterval)
+
+	r := app.NewRouter(bootstrap.RouterConfig{
+		RateLimiter: app.RateLimiter("settlement", 60, time.Minute).WithAdaptive(5, 15*time.Minute),
+		// A manual run settles the whole pending batch.
+		Deadlines: middleware.NewQueryDeadlines(app.DBConfig.QueryTimeout).WithPrefix("/api/v1/settlements/process", app.DBConfig.ReportTimeout),
+	})
+
+	// Auth Middleware
+	blacklist := app.TokenBlacklist()
+	authMW := middleware.NewAuthMiddlewareWithUserStatus(cfg.JWT.Secret, blacklist, &userStatusChecker{repo: userRepo})
+
+	// Admin routes for manual settlement triggers
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(authMW.Authenticate)
+	authz := middleware.NewAuthorizer(newRoles(app, userRepo), log)
+	api.Use(authz.Load)
+	process := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := settlementService.ProcessPendingSettlements(r.Context()); err != nil {
+			status := http.StatusInternalServerError
+			if err == settlement.ErrSubmissionPaused {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"processing"}`))
+	})
+	api.Handle("/settlements/process", authz.Require(domain.PermissionTreasuryWrite)(process)).Methods("POST")
+
+	return r, nil
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	"kyd/internal/standing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// standingPayments makes standing instructions' conversions through the
+// payment service, as the scheduler's trusted system device.
+type standingPayments struct {
+	payments *payment.Service
+}
+
+func (p standingPayments) Convert(ctx context.Context, c *standing.Conversion) (*domain.Transaction, error) {
+	resp, err := p.payments.InitiatePayment(ctx, &payment.InitiatePaymentRequest{
+		SenderID:            c.UserID,
+		ReceiverID:          c.UserID,
+		Amount:              c.Amount,
+		Currency:            c.From,
+		DestinationCurrency: c.To,
+		Description:         c.Description,
+		Channel:             "api",
+		Category:            standing.Category,
+		Reference:           c.Reference,
+		DeviceID:            "system-scheduler",
+		Location:            "Internal",
+		Metadata:            c.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Transaction, nil
+}
+
+func (p standingPayments) FeeRate(ctx context.Context, userID uuid.UUID) (decimal.Decimal, error) {
+	return p.payments.FeeRate(ctx, userID)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"kyd/internal/activity"
+	"kyd/internal/auth"
+	"kyd/internal/funding"
+	kydgrpc "kyd/internal/grpc"
+	"kyd/internal/grpc/kydv1"
+	"kyd/internal/handler"
+	"kyd/internal/ledger"
+	"kyd/internal/freeze"
+	"kyd/internal/maintenance"
+	"kyd/internal/middleware"
+	"kyd/internal/repository/postgres"
+	"kyd/internal/risk"
+	"kyd/internal/security"
+	"kyd/internal/wallet"
+	"kyd/pkg/bootstrap"
+	"kyd/pkg/config"
+	"kyd/pkg/errors"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/shopspring/decimal"
+)
+
+// Wallet builds the wallet service's router. app must be connected to the
+// database and Redis.
+func Wallet(app *bootstrap.App) (http.Handler, error) {
+	cfg, log := app.Config, app.Logger
+	db, redisClient := app.DB, app.Redis
+
+	// Initialize security service
+	cryptoService, err := security.NewCryptoService()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize crypto service")
+	}
+
+	// Initialize repositories
+	walletRepo := postgres.NewWalletRepository(db)
+	userRepo := postgres.NewUserRepository(db, cryptoService)
+	txRepo := postgres.NewTransactionRepository(db)
+	if cfg.Outbox.Enabled {
+		txRepo.WithOutbox()
+	}
+
+	// Wallet history is served from the activity feed; this service also
+	// runs the catch-up pass that keeps it complete
+	projector := newActivityProjector(cfg.Activity, app, cryptoService, txRepo, userRepo, walletRepo, log).WithCatchUp(txRepo)
+	app.Start(projector)
+
+	// Initialize services
+	walletService := wallet.NewService(walletRepo, txRepo, userRepo, log).WithActivity(projector)
+	ledgerService := ledger.NewService(db, postgres.NewLedgerRepository(db))
+	fundingService, err := newFundingService(cfg.Funding, txRepo, walletRepo, userRepo, ledgerService, log)
+	if err != nil {
+		return nil, err
+	}
+	fundingService.WithFreezes(freeze.NewService(postgres.NewFreezeRepository(db), cfg.Freeze, log)).
+		WithScreening(postgres.NewSecurityRepository(db), postgres.NewSpendingControlRepository(db), risk.NewRiskEngine(cfg.Risk))
+
+	// Initialize handlers
+	val := validator.New()
+	walletHandler := handler.NewWalletHandler(walletService, val, log)
+	fundingHandler := handler.NewFundingHandler(fundingService, log)
+
+	// Internal gRPC API over the same wallet service
+	if cfg.GRPC.Enabled {
+		kydv1.RegisterWalletServiceServer(app.GRPC(), kydgrpc.NewWalletServer(walletService))
+	}
+
+	r := app.NewRouter(bootstrap.RouterConfig{
+		RateLimiter: app.RateLimiter("wallet", 120, time.Minute).WithAdaptive(10, 30*time.Minute),
+	})
+
+	// Payment providers sign their deposit and payout callbacks instead of logging in
+	r.HandleFunc("/api/v1/deposits/callbacks/{provider}", fundingHandler.Callback).Methods("POST")
+	r.HandleFunc("/api/v1/withdrawals/callbacks/{provider}", fundingHandler.PayoutCallback).Methods("POST")
+
+	blacklist := app.TokenBlacklist()
+	authMW := middleware.NewAuthMiddlewareWithUserStatus(cfg.JWT.Secret, blacklist, &userStatusChecker{repo: userRepo})
+
+	// Protected routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(authMW.Authenticate)
+	api.Use(middleware.NewAuthorizer(newRoles(app, userRepo), log).Load)
+	api.Use(app.RateLimiter("wallet-api", 80, time.Minute).WithAdaptive(5, 15*time.Minute).Limit)
+
+	requireVerifiedEmail := middleware.RequireVerifiedEmail(auth.NewVerificationGate(userRepo, auth.VerificationPolicyFromConfig(cfg.Verification)))
+
+	api.Handle("/wallets", requireVerifiedEmail(http.HandlerFunc(walletHandler.CreateWallet))).Methods("POST")
+	inMaintenance := middleware.RejectDuringMaintenance(maintenance.NewMode(maintenance.NewRedisStore(redisClient), cfg.Maintenance), maintenance.ServiceWallet)
+	api.Handle("/wallets/{id}/deposit", inMaintenance(http.HandlerFunc(fundingHandler.Deposit))).Methods("POST")
+	api.Handle("/wallets/{id}/withdraw", inMaintenance(requireVerifiedEmail(http.HandlerFunc(fundingHandler.Withdraw)))).Methods("POST")
+	api.HandleFunc("/wallets/search", walletHandler.SearchWallets).Methods("GET")
+	api.HandleFunc("/wallets/lookup", walletHandler.LookupWallet).Methods("GET")
+	api.HandleFunc("/wallets", walletHandler.GetUserWallets).Methods("GET")
+	api.HandleFunc("/wallets/{id}", walletHandler.GetWallet).Methods("GET")
+	api.HandleFunc("/wallets/{id}/balance", walletHandler.GetBalance).Methods("GET")
+	api.HandleFunc("/wallets/{id}/history", walletHandler.GetActivity).Methods("GET")
+
+	return r, nil
+}
+
+// newActivityProjector builds the wallet activity projector and, when the
+// feed is enabled, has txRepo report each transaction it stores to it.
+func newActivityProjector(cfg config.ActivityConfig, app *bootstrap.App, crypto *security.CryptoService, txRepo *postgres.TransactionRepository, users activity.UserRepository, wallets activity.WalletRepository, log logger.Logger) *activity.Projector {
+	p := activity.NewProjector(postgres.NewWalletActivityRepository(app.DB, crypto), users, wallets, cfg, log)
+	if cfg.Enabled {
+		txRepo.WithListener(p)
+	}
+	return p
+}
+
+// newFundingService builds the top-up and withdrawal service with the
+// providers cfg names, in its order of preference.
+func newFundingService(cfg config.FundingConfig, txs funding.TransactionRepository, wallets funding.WalletRepository, users funding.UserRepository, l funding.Ledger, log logger.Logger) (*funding.Service, error) {
+	s := funding.NewService(txs, wallets, users, l, log).
+		WithApprovalThreshold(decimal.NewFromFloat(cfg.WithdrawalApprovalThreshold))
+	for _, name := range cfg.Providers {
+		switch name {
+		case "mock":
+			mock := funding.NewMockProvider(cfg.MockSecret)
+			s.WithProvider(mock).WithPayoutProvider(mock)
+		case "paychangu":
+			s.WithProvider(funding.NewPayChanguProvider(funding.PayChanguConfig{
+				BaseURL:       cfg.PayChanguURL,
+				SecretKey:     cfg.PayChanguSecretKey,
+				WebhookSecret: cfg.PayChanguWebhookSecret,
+				CallbackURL:   strings.TrimRight(cfg.CallbackBaseURL, "/") + "/api/v1/deposits/callbacks/paychangu",
+				ReturnURL:     cfg.ReturnURL,
+				Timeout:       cfg.Timeout,
+			}))
+		default:
+			return nil, errors.New("invalid FUNDING_PROVIDERS: unknown provider " + name)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,44 @@
+package settlement
+
+import (
+	"context"
+
+	"kyd/internal/domain"
+)
+
+// CounterpartyGate decides which counterparty a settlement is paid out
+// through. Satisfied by counterparty.Service.
+type CounterpartyGate interface {
+	// Admit assigns the settlement to a counterparty and draws its
+	// prefunding, or returns why the settlement must wait.
+	Admit(ctx context.Context, set *domain.Settlement) (string, error)
+	// Return gives back the prefunding drawn for a settlement that failed
+	// to submit.
+	Return(ctx context.Context, set *domain.Settlement) error
+}
+
+// WithCounterparties holds settlements back while their corridor's
+// counterparties are suspended or short of prefunding.
+func (s *Service) WithCounterparties(g CounterpartyGate) *Service {
+	s.counterparties = g
+	return s
+}
+
+func (s *Service) admitCounterparty(ctx context.Context, set *domain.Settlement) (string, error) {
+	if s.counterparties == nil {
+		return "", nil
+	}
+	return s.counterparties.Admit(ctx, set)
+}
+
+func (s *Service) returnCounterparty(ctx context.Context, set *domain.Settlement) {
+	if s.counterparties == nil {
+		return
+	}
+	if err := s.counterparties.Return(ctx, set); err != nil {
+		s.logger.Error("Failed to return counterparty prefunding", map[string]interface{}{
+			"settlement_id": set.ID,
+			"error":         err.Error(),
+		})
+	}
+}
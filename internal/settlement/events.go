@@ -0,0 +1,46 @@
+package settlement
+
+import (
+	"context"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// EventRecorder appends to the transaction event stream.
+type EventRecorder interface {
+	Append(ctx context.Context, e *domain.TransactionEvent) error
+}
+
+// WithEventStream records settlement milestones against each transaction in a batch.
+func (s *Service) WithEventStream(r EventRecorder) *Service {
+	s.events = r
+	return s
+}
+
+// recordEvent appends the same milestone for every transaction. Failures are
+// logged and never interrupt settlement.
+func (s *Service) recordEvent(ctx context.Context, txs []*domain.Transaction, t domain.TransactionEventType, details domain.Metadata) {
+	if s.events == nil {
+		return
+	}
+	now := time.Now()
+	for _, tx := range txs {
+		err := s.events.Append(ctx, &domain.TransactionEvent{
+			ID:            uuid.New(),
+			TransactionID: tx.ID,
+			Type:          t,
+			Details:       details,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			s.logger.Warn("Failed to record transaction event", map[string]interface{}{
+				"transaction_id": tx.ID,
+				"event":          t,
+				"error":          err.Error(),
+			})
+		}
+	}
+}
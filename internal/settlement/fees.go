@@ -0,0 +1,252 @@
+package settlement
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+
+	"github.com/shopspring/decimal"
+)
+
+// FeeStrategy controls how aggressively we bid for inclusion on a network.
+type FeeStrategy string
+
+const (
+	FeeStrategyEconomy  FeeStrategy = "economy"
+	FeeStrategyStandard FeeStrategy = "standard"
+	FeeStrategyFast     FeeStrategy = "fast"
+)
+
+// Priority classes a settlement can fall into. Callers can force a class by
+// setting metadata["priority"]; otherwise it is derived from the batch total.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// FeeQuote is a connector's view of current fee conditions on its network.
+type FeeQuote struct {
+	// BaseFee is the fee for a standard-priority settlement at current load,
+	// expressed in the settlement currency.
+	BaseFee decimal.Decimal
+	// Congestion is the network load from 0 (idle) to 1 (saturated).
+	Congestion float64
+}
+
+// FeeEstimator is implemented by connectors that can price a submission
+// before it is made. Connectors that do not implement it are charged the
+// policy's DefaultFee.
+type FeeEstimator interface {
+	EstimateFee(ctx context.Context, settlement *domain.Settlement) (*FeeQuote, error)
+}
+
+// FeeEstimate is the fee decision for a single submission attempt.
+type FeeEstimate struct {
+	Strategy   FeeStrategy
+	BaseFee    decimal.Decimal
+	Fee        decimal.Decimal
+	Congestion float64
+	// DeferReason is set when the settlement should be held back rather than submitted.
+	DeferReason string
+}
+
+type FeePolicy struct {
+	// Batches at or above HighValueThreshold are high priority; below
+	// LowValueThreshold they are low priority.
+	HighValueThreshold decimal.Decimal
+	LowValueThreshold  decimal.Decimal
+
+	Strategies  map[string]FeeStrategy
+	Multipliers map[FeeStrategy]decimal.Decimal
+
+	// Caps is the maximum fee we will pay per network. A zero or missing cap means uncapped.
+	Caps map[domain.BlockchainNetwork]decimal.Decimal
+	// CongestionLimit is the load above which non-fast settlements are deferred.
+	CongestionLimit float64
+	// MaxDeferral is how long a settlement can be held back for congestion before it
+	// is escalated to the fast strategy. Fee caps still apply after escalation.
+	MaxDeferral time.Duration
+
+	DefaultFee decimal.Decimal
+}
+
+func DefaultFeePolicy() FeePolicy {
+	return FeePolicy{
+		HighValueThreshold: decimal.NewFromInt(100000),
+		LowValueThreshold:  decimal.NewFromInt(1000),
+		Strategies: map[string]FeeStrategy{
+			PriorityLow:    FeeStrategyEconomy,
+			PriorityNormal: FeeStrategyStandard,
+			PriorityHigh:   FeeStrategyFast,
+		},
+		Multipliers: map[FeeStrategy]decimal.Decimal{
+			FeeStrategyEconomy:  decimal.NewFromFloat(0.8),
+			FeeStrategyStandard: decimal.NewFromInt(1),
+			FeeStrategyFast:     decimal.NewFromFloat(1.5),
+		},
+		Caps:            map[domain.BlockchainNetwork]decimal.Decimal{},
+		CongestionLimit: 0.8,
+		MaxDeferral:     30 * time.Minute,
+		DefaultFee:      decimal.Zero,
+	}
+}
+
+// FeePolicyFromConfig builds a fee policy from service configuration.
+func FeePolicyFromConfig(cfg config.SettlementConfig) FeePolicy {
+	p := DefaultFeePolicy()
+	if cfg.HighPriorityThreshold > 0 {
+		p.HighValueThreshold = decimal.NewFromInt(cfg.HighPriorityThreshold)
+	}
+	if cfg.LowPriorityThreshold > 0 {
+		p.LowValueThreshold = decimal.NewFromInt(cfg.LowPriorityThreshold)
+	}
+	if cfg.StellarFeeCap > 0 {
+		p.Caps[domain.NetworkStellar] = decimal.NewFromFloat(cfg.StellarFeeCap)
+	}
+	if cfg.RippleFeeCap > 0 {
+		p.Caps[domain.NetworkRipple] = decimal.NewFromFloat(cfg.RippleFeeCap)
+	}
+	p.CongestionLimit = cfg.FeeCongestionLimit
+	p.MaxDeferral = cfg.MaxFeeDeferral
+	return p
+}
+
+// WithFeePolicy overrides the default fee policy.
+func (s *Service) WithFeePolicy(p FeePolicy) *Service {
+	s.feePolicy = p
+	return s
+}
+
+func (p FeePolicy) priorityClass(set *domain.Settlement) string {
+	if v, ok := set.Metadata["priority"].(string); ok {
+		if _, known := p.Strategies[v]; known {
+			return v
+		}
+	}
+	switch {
+	case set.TotalAmount.GreaterThanOrEqual(p.HighValueThreshold):
+		return PriorityHigh
+	case set.TotalAmount.LessThan(p.LowValueThreshold):
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// estimateFee prices a submission for the settlement's network and decides
+// whether it should go now or be deferred.
+func (s *Service) estimateFee(ctx context.Context, set *domain.Settlement, connector BlockchainConnector) (*FeeEstimate, error) {
+	p := s.feePolicy
+	est := &FeeEstimate{
+		Strategy: p.Strategies[p.priorityClass(set)],
+		BaseFee:  p.DefaultFee,
+	}
+	if est.Strategy == "" {
+		est.Strategy = FeeStrategyStandard
+	}
+
+	if fe, ok := connector.(FeeEstimator); ok {
+		quote, err := fe.EstimateFee(ctx, set)
+		if err != nil {
+			return nil, fmt.Errorf("estimate fee: %w", err)
+		}
+		est.BaseFee = quote.BaseFee
+		est.Congestion = quote.Congestion
+		if s.throttle != nil {
+			s.throttle.ObserveCongestion(set.Network, quote.Congestion)
+		}
+	}
+
+	congested := p.CongestionLimit > 0 && est.Congestion > p.CongestionLimit
+	if congested && est.Strategy != FeeStrategyFast {
+		if p.MaxDeferral > 0 && time.Since(set.CreatedAt) >= p.MaxDeferral {
+			est.Strategy = FeeStrategyFast
+		} else {
+			est.DeferReason = fmt.Sprintf("network congested (%.2f)", est.Congestion)
+		}
+	}
+
+	multiplier, ok := p.Multipliers[est.Strategy]
+	if !ok {
+		multiplier = decimal.NewFromInt(1)
+	}
+	est.Fee = est.BaseFee.Mul(multiplier)
+
+	if limit, ok := p.Caps[set.Network]; ok && limit.IsPositive() && est.Fee.GreaterThan(limit) {
+		est.DeferReason = fmt.Sprintf("fee %s exceeds cap %s", est.Fee.String(), limit.String())
+	}
+	return est, nil
+}
+
+// deferSettlement holds a settlement back for a later attempt.
+func (s *Service) deferSettlement(ctx context.Context, set *domain.Settlement, est *FeeEstimate) error {
+	if set.Metadata == nil {
+		set.Metadata = make(domain.Metadata)
+	}
+	deferrals, _ := set.Metadata["fee_deferrals"].(float64)
+	set.Metadata["fee_deferred"] = true
+	set.Metadata["fee_deferred_reason"] = est.DeferReason
+	set.Metadata["fee_deferrals"] = deferrals + 1
+	if !est.Fee.IsZero() {
+		set.Metadata["estimated_fee"] = est.Fee.String()
+	}
+	set.Status = domain.SettlementStatusPending
+	set.UpdatedAt = time.Now()
+
+	s.logger.Warn("Settlement deferred", map[string]interface{}{
+		"settlement_id": set.ID,
+		"network":       set.Network,
+		"reason":        est.DeferReason,
+	})
+	return s.repo.Update(ctx, set)
+}
+
+// applyFee records the chosen strategy before submission and the fee the
+// network actually charged afterwards.
+func applyFee(set *domain.Settlement, est *FeeEstimate, result *SettlementResult) {
+	if set.Metadata == nil {
+		set.Metadata = make(domain.Metadata)
+	}
+	delete(set.Metadata, "fee_deferred")
+	delete(set.Metadata, "fee_deferred_reason")
+	set.Metadata["fee_strategy"] = string(est.Strategy)
+	set.Metadata["estimated_fee"] = est.Fee.String()
+	set.Metadata["network_congestion"] = est.Congestion
+
+	set.NetworkFee = est.Fee
+	if result != nil && !result.Fee.IsZero() {
+		set.NetworkFee = result.Fee
+	}
+}
+
+// ProcessDeferredSettlements retries settlements that were held back by the fee policy.
+func (s *Service) ProcessDeferredSettlements(ctx context.Context) error {
+	if s.submissionPaused(ctx) {
+		return ErrSubmissionPaused
+	}
+	pending, err := s.repo.FindAllWithFilters(ctx, 100, 0, string(domain.SettlementStatusPending), "", "")
+	if err != nil {
+		return err
+	}
+	for _, set := range pending {
+		if deferred, _ := set.Metadata["fee_deferred"].(bool); !deferred {
+			continue
+		}
+		if err := s.submit(ctx, set); err != nil {
+			s.logger.Error("Deferred settlement submission failed", map[string]interface{}{
+				"settlement_id": set.ID,
+				"error":         err.Error(),
+			})
+		}
+	}
+	return nil
+}
+
+// NetworkFeeReport summarises the fees paid on each network between from and to.
+func (s *Service) NetworkFeeReport(ctx context.Context, from, to time.Time) ([]*domain.NetworkFeeSummary, error) {
+	return s.repo.SummarizeNetworkFees(ctx, from, to)
+}
@@ -0,0 +1,158 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFeeConnector struct {
+	MockBlockchainConnector
+}
+
+func (m *MockFeeConnector) EstimateFee(ctx context.Context, settlement *domain.Settlement) (*FeeQuote, error) {
+	args := m.Called(ctx, settlement)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*FeeQuote), args.Error(1)
+}
+
+func newFeeTestService(policy FeePolicy) *Service {
+	return &Service{logger: new(MockLogger), feePolicy: policy}
+}
+
+func TestEstimateFee_StrategyByPriority(t *testing.T) {
+	s := newFeeTestService(DefaultFeePolicy())
+	conn := new(MockFeeConnector)
+	conn.On("EstimateFee", mock.Anything, mock.Anything).Return(&FeeQuote{BaseFee: decimal.NewFromInt(10)}, nil)
+
+	cases := []struct {
+		amount   int64
+		strategy FeeStrategy
+		fee      decimal.Decimal
+	}{
+		{500, FeeStrategyEconomy, decimal.NewFromInt(8)},
+		{5000, FeeStrategyStandard, decimal.NewFromInt(10)},
+		{250000, FeeStrategyFast, decimal.NewFromInt(15)},
+	}
+	for _, c := range cases {
+		set := &domain.Settlement{TotalAmount: decimal.NewFromInt(c.amount), Network: domain.NetworkStellar, CreatedAt: time.Now()}
+		est, err := s.estimateFee(context.Background(), set, conn)
+		assert.NoError(t, err)
+		assert.Equal(t, c.strategy, est.Strategy)
+		assert.True(t, c.fee.Equal(est.Fee))
+		assert.Empty(t, est.DeferReason)
+	}
+}
+
+func TestEstimateFee_DefersOverCap(t *testing.T) {
+	policy := DefaultFeePolicy()
+	policy.Caps[domain.NetworkRipple] = decimal.NewFromInt(12)
+	s := newFeeTestService(policy)
+	conn := new(MockFeeConnector)
+	conn.On("EstimateFee", mock.Anything, mock.Anything).Return(&FeeQuote{BaseFee: decimal.NewFromInt(10)}, nil)
+
+	set := &domain.Settlement{TotalAmount: decimal.NewFromInt(250000), Network: domain.NetworkRipple, CreatedAt: time.Now()}
+	est, err := s.estimateFee(context.Background(), set, conn)
+	assert.NoError(t, err)
+	assert.Contains(t, est.DeferReason, "exceeds cap")
+}
+
+func TestEstimateFee_CongestionDefersThenEscalates(t *testing.T) {
+	s := newFeeTestService(DefaultFeePolicy())
+	conn := new(MockFeeConnector)
+	conn.On("EstimateFee", mock.Anything, mock.Anything).Return(&FeeQuote{BaseFee: decimal.NewFromInt(10), Congestion: 0.95}, nil)
+
+	fresh := &domain.Settlement{TotalAmount: decimal.NewFromInt(5000), Network: domain.NetworkStellar, CreatedAt: time.Now()}
+	est, err := s.estimateFee(context.Background(), fresh, conn)
+	assert.NoError(t, err)
+	assert.Contains(t, est.DeferReason, "congested")
+
+	stale := &domain.Settlement{TotalAmount: decimal.NewFromInt(5000), Network: domain.NetworkStellar, CreatedAt: time.Now().Add(-time.Hour)}
+	est, err = s.estimateFee(context.Background(), stale, conn)
+	assert.NoError(t, err)
+	assert.Empty(t, est.DeferReason)
+	assert.Equal(t, FeeStrategyFast, est.Strategy)
+}
+
+func TestSubmit_RecordsActualFee(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockLog := new(MockLogger)
+	conn := new(MockFeeConnector)
+	s := &Service{repo: mockRepo, stellarConnector: conn, logger: mockLog, feePolicy: DefaultFeePolicy(), monitorInterval: time.Hour}
+
+	set := &domain.Settlement{ID: uuid.New(), TotalAmount: decimal.NewFromInt(5000), Network: domain.NetworkStellar, CreatedAt: time.Now(), Metadata: domain.Metadata{}}
+	conn.On("EstimateFee", mock.Anything, set).Return(&FeeQuote{BaseFee: decimal.NewFromInt(2)}, nil)
+	conn.On("SubmitSettlement", mock.Anything, set).Return(&SettlementResult{TxHash: "", Fee: decimal.NewFromFloat(1.5)}, nil)
+	mockRepo.On("Update", mock.Anything, set).Return(nil)
+	mockLog.On("Info", "Settlement submitted", mock.Anything).Return()
+
+	assert.NoError(t, s.submit(context.Background(), set))
+	assert.Equal(t, domain.SettlementStatusSubmitted, set.Status)
+	assert.True(t, set.NetworkFee.Equal(decimal.NewFromFloat(1.5)))
+	assert.Equal(t, "standard", set.Metadata["fee_strategy"])
+}
+
+type corridorFreeze string
+
+func (c corridorFreeze) Check(ctx context.Context, m domain.Movement) error {
+	if string(m.From)+"-"+string(m.To) == string(c) {
+		return errors.New("money movement is frozen: corridor paused")
+	}
+	return nil
+}
+
+func TestSubmit_DefersFrozenCorridor(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockLog := new(MockLogger)
+	conn := new(MockFeeConnector)
+	s := (&Service{repo: mockRepo, stellarConnector: conn, logger: mockLog, feePolicy: DefaultFeePolicy()}).
+		WithFreezes(corridorFreeze("MWK-ZAR"))
+
+	set := &domain.Settlement{ID: uuid.New(), TotalAmount: decimal.NewFromInt(5000), Network: domain.NetworkStellar, CreatedAt: time.Now(), Metadata: domain.Metadata{"corridor": "MWK-ZAR"}}
+	mockRepo.On("Update", mock.Anything, set).Return(nil)
+	mockLog.On("Warn", "Settlement deferred", mock.Anything).Return()
+
+	assert.NoError(t, s.submit(context.Background(), set))
+	assert.Equal(t, domain.SettlementStatusPending, set.Status)
+	assert.Equal(t, true, set.Metadata["fee_deferred"])
+	assert.Contains(t, set.Metadata["fee_deferred_reason"], "corridor paused")
+	conn.AssertNotCalled(t, "SubmitSettlement", mock.Anything, mock.Anything)
+}
+
+type restrictedCountry string
+
+func (c restrictedCountry) Check(ctx context.Context, f domain.JurisdictionFlow) error {
+	for _, d := range f.Destinations {
+		if d == string(c) {
+			return errors.New("jurisdiction is restricted: payments to " + d + " are not permitted")
+		}
+	}
+	return nil
+}
+
+func TestSubmit_DefersRestrictedJurisdiction(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockLog := new(MockLogger)
+	conn := new(MockFeeConnector)
+	s := (&Service{repo: mockRepo, stellarConnector: conn, logger: mockLog, feePolicy: DefaultFeePolicy()}).
+		WithJurisdictions(restrictedCountry("ZA"))
+
+	set := &domain.Settlement{ID: uuid.New(), TotalAmount: decimal.NewFromInt(5000), Network: domain.NetworkStellar, CreatedAt: time.Now(), Metadata: domain.Metadata{"corridor": "MWK-ZAR"}}
+	mockRepo.On("Update", mock.Anything, set).Return(nil)
+	mockLog.On("Warn", "Settlement deferred", mock.Anything).Return()
+
+	assert.NoError(t, s.submit(context.Background(), set))
+	assert.Equal(t, true, set.Metadata["fee_deferred"])
+	assert.Contains(t, set.Metadata["fee_deferred_reason"], "payments to ZA are not permitted")
+	conn.AssertNotCalled(t, "SubmitSettlement", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,39 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+
+	"kyd/internal/domain"
+)
+
+// FreezeChecker refuses money movements an admin has frozen. Satisfied by
+// freeze.Service.
+type FreezeChecker interface {
+	Check(ctx context.Context, m domain.Movement) error
+}
+
+// WithFreezes holds back settlements whose corridor is covered by an active
+// money movement freeze. They are deferred like settlements the fee policy
+// holds back, and submitted once the freeze is lifted.
+func (s *Service) WithFreezes(f FreezeChecker) *Service {
+	s.freezes = f
+	return s
+}
+
+// frozen returns why the settlement may not be submitted, or "".
+func (s *Service) frozen(ctx context.Context, set *domain.Settlement) string {
+	if s.freezes == nil {
+		return ""
+	}
+	corridor, _ := set.Metadata["corridor"].(string)
+	from, to, ok := strings.Cut(corridor, "-")
+	if !ok {
+		from, to = string(set.Currency), string(set.Currency)
+	}
+	m := domain.Movement{Kind: domain.MovementSettlement, From: domain.Currency(from), To: domain.Currency(to)}
+	if err := s.freezes.Check(ctx, m); err != nil {
+		return err.Error()
+	}
+	return ""
+}
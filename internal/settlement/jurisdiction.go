@@ -0,0 +1,39 @@
+package settlement
+
+import (
+	"context"
+	"strings"
+
+	"kyd/internal/domain"
+)
+
+// JurisdictionChecker refuses money moving out of or into a restricted
+// jurisdiction. Satisfied by jurisdiction.Service.
+type JurisdictionChecker interface {
+	Check(ctx context.Context, f domain.JurisdictionFlow) error
+}
+
+// WithJurisdictions holds back settlements whose corridor leaves a country
+// restricted inbound or reaches one restricted outbound, such as payments
+// accepted before an embargo took effect. They are deferred like frozen
+// settlements, and submitted once the restriction ends.
+func (s *Service) WithJurisdictions(j JurisdictionChecker) *Service {
+	s.jurisdictions = j
+	return s
+}
+
+// restricted returns why the settlement may not be submitted, or "".
+func (s *Service) restricted(ctx context.Context, set *domain.Settlement) string {
+	if s.jurisdictions == nil {
+		return ""
+	}
+	corridor, _ := set.Metadata["corridor"].(string)
+	from, to, ok := strings.Cut(corridor, "-")
+	if !ok {
+		from, to = string(set.Currency), string(set.Currency)
+	}
+	if err := s.jurisdictions.Check(ctx, domain.CorridorFlow(domain.Currency(from), domain.Currency(to))); err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// maintenanceService is the name settlement is known by in maintenance mode.
+const maintenanceService = "settlement"
+
+var ErrSubmissionPaused = errors.New("settlement submission is paused for maintenance")
+
+// MaintenanceChecker reports whether a service is in maintenance.
+type MaintenanceChecker interface {
+	InMaintenance(ctx context.Context, service string) (message string, retryAfter time.Duration, active bool)
+}
+
+// WithMaintenance pauses new settlement submissions while settlement (or the
+// whole system) is in maintenance. Monitoring of settlements already on the
+// network carries on.
+func (s *Service) WithMaintenance(m MaintenanceChecker) *Service {
+	s.maintenance = m
+	return s
+}
+
+func (s *Service) submissionPaused(ctx context.Context) bool {
+	if s.maintenance == nil {
+		return false
+	}
+	_, _, active := s.maintenance.InMaintenance(ctx, maintenanceService)
+	return active
+}
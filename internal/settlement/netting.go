@@ -0,0 +1,338 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// SettlementMode is how a corridor's payments reach the network.
+type SettlementMode string
+
+const (
+	// SettlementModeGross settles every payment on its own as soon as it is
+	// pending (RTGS style).
+	SettlementModeGross SettlementMode = "gross"
+	// SettlementModeNet holds both directions of a corridor until the window
+	// closes and settles only the difference.
+	SettlementModeNet SettlementMode = "net"
+)
+
+// SettlementModeKey is the settlement metadata key recording the mode a
+// gross or net settlement was made under.
+const SettlementModeKey = "settlement_mode"
+
+// nettingBatchLimit bounds the payments read per corridor per run. It is
+// deliberately large: a window netted across two runs settles twice.
+const nettingBatchLimit = 10000
+
+// settlementInterval is how often the worker settles pending payments.
+const settlementInterval = 30 * time.Second
+
+var ErrNettingNotFound = errors.New("settlement was not netted")
+
+// CorridorMode selects the settlement mode of one corridor, e.g. "MWK-ZAR".
+// It covers both directions. Window is the net settlement window; windows
+// are aligned to UTC, so a 1h window closes on the hour.
+type CorridorMode struct {
+	Corridor string
+	Mode     SettlementMode
+	Window   time.Duration
+}
+
+// ParseCorridorModes parses SETTLEMENT_CORRIDOR_MODES entries such as
+// "MWK-ZAR=net:1h" or "MWK-CNY=gross". Corridors left out keep the default
+// batching.
+func ParseCorridorModes(specs []string) ([]CorridorMode, error) {
+	var modes []CorridorMode
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		corridor, mode, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("corridor mode %q must look like MWK-ZAR=net:1h", spec)
+		}
+		corridor = strings.ToUpper(strings.TrimSpace(corridor))
+		base, quote, ok := strings.Cut(corridor, "-")
+		if !ok || len(base) != 3 || len(quote) != 3 || base == quote {
+			return nil, fmt.Errorf("corridor mode %q: corridor must look like MWK-ZAR", spec)
+		}
+		if seen[corridor] || seen[quote+"-"+base] {
+			return nil, fmt.Errorf("corridor mode %q: %s is configured twice", spec, corridor)
+		}
+		seen[corridor] = true
+
+		cm := CorridorMode{Corridor: corridor}
+		name, window, _ := strings.Cut(strings.TrimSpace(mode), ":")
+		switch SettlementMode(strings.ToLower(name)) {
+		case SettlementModeGross:
+			cm.Mode = SettlementModeGross
+		case SettlementModeNet:
+			d, err := time.ParseDuration(window)
+			if err != nil || d < time.Minute {
+				return nil, fmt.Errorf("corridor mode %q: net needs a window of at least 1m, e.g. net:1h", spec)
+			}
+			cm.Mode, cm.Window = SettlementModeNet, d
+		default:
+			return nil, fmt.Errorf("corridor mode %q: mode must be gross or net", spec)
+		}
+		modes = append(modes, cm)
+	}
+	return modes, nil
+}
+
+// pairs returns the corridor's currency pair in both directions.
+func (m CorridorMode) pairs() []string {
+	base, quote, _ := strings.Cut(m.Corridor, "-")
+	return []string{m.Corridor, quote + "-" + base}
+}
+
+// NettingRepository reads payments by corridor and keeps the audit of every
+// netted window.
+type NettingRepository interface {
+	// FindPendingSettlementExcept is FindPendingSettlement without the
+	// given currency pairs, so payments waiting on a net window never hold
+	// up other corridors.
+	FindPendingSettlementExcept(ctx context.Context, pairs []string, limit int) ([]*domain.Transaction, error)
+	// FindNettable returns payments in the given pairs awaiting settlement
+	// that completed before the given time.
+	FindNettable(ctx context.Context, pairs []string, before time.Time, limit int) ([]*domain.Transaction, error)
+	CreateNetting(ctx context.Context, n *domain.SettlementNetting) error
+	FindNettingBySettlementID(ctx context.Context, settlementID uuid.UUID) (*domain.SettlementNetting, error)
+}
+
+// WithCorridorModes settles the given corridors gross or net instead of in
+// the default batches.
+func (s *Service) WithCorridorModes(modes []CorridorMode, repo NettingRepository) *Service {
+	s.modes = map[string]CorridorMode{}
+	for _, m := range modes {
+		for _, pair := range m.pairs() {
+			s.modes[pair] = m
+		}
+	}
+	s.netting = repo
+	return s
+}
+
+func (s *Service) modeFor(pair string) SettlementMode {
+	return s.modes[pair].Mode
+}
+
+// SettledBy estimates when a payment from one currency to another that is
+// ready to settle at readyAt will have settled: by the worker's next run,
+// or for a net corridor by the next run after its window closes.
+func (s *Service) SettledBy(from, to domain.Currency, readyAt time.Time) time.Time {
+	if m := s.modes[string(from)+"-"+string(to)]; m.Mode == SettlementModeNet {
+		readyAt = readyAt.Truncate(m.Window).Add(m.Window)
+	}
+	return readyAt.Add(settlementInterval)
+}
+
+// netPairs lists both directions of every net corridor.
+func (s *Service) netPairs() []string {
+	var pairs []string
+	for pair, m := range s.modes {
+		if m.Mode == SettlementModeNet {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// pendingSettlements returns the payments to settle gross or in batches now.
+func (s *Service) pendingSettlements(ctx context.Context, limit int) ([]*domain.Transaction, error) {
+	pairs := s.netPairs()
+	if len(pairs) == 0 || s.netting == nil {
+		return s.txRepo.FindPendingSettlement(ctx, limit)
+	}
+	return s.netting.FindPendingSettlementExcept(ctx, pairs, limit)
+}
+
+// ProcessNetSettlements nets every closed window of the net corridors and
+// settles each window's net position in one settlement.
+func (s *Service) ProcessNetSettlements(ctx context.Context, now time.Time) error {
+	if s.netting == nil {
+		return nil
+	}
+	var corridors []CorridorMode
+	for pair, m := range s.modes {
+		if m.Mode == SettlementModeNet && pair == m.Corridor {
+			corridors = append(corridors, m)
+		}
+	}
+	sort.Slice(corridors, func(i, j int) bool { return corridors[i].Corridor < corridors[j].Corridor })
+
+	for _, m := range corridors {
+		txs, err := s.netting.FindNettable(ctx, m.pairs(), now.Truncate(m.Window), nettingBatchLimit)
+		if err != nil {
+			return err
+		}
+		windows := make(map[time.Time][]*domain.Transaction)
+		for _, tx := range txs {
+			start := nettingTime(tx).Truncate(m.Window)
+			windows[start] = append(windows[start], tx)
+		}
+		starts := make([]time.Time, 0, len(windows))
+		for start := range windows {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+		for _, start := range starts {
+			if err := s.settleNet(ctx, m, start, windows[start]); err != nil {
+				s.logger.Error("Net settlement failed", map[string]interface{}{
+					"corridor":     m.Corridor,
+					"window_start": start,
+					"count":        len(windows[start]),
+					"error":        err.Error(),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// nettingTime places a payment in a window: when it became ready to settle.
+func nettingTime(tx *domain.Transaction) time.Time {
+	if tx.CompletedAt != nil {
+		return *tx.CompletedAt
+	}
+	return tx.CreatedAt
+}
+
+// netPosition nets one window of a corridor. See domain.SettlementNetting
+// for how the position is measured.
+func netPosition(m CorridorMode, start time.Time, txs []*domain.Transaction) *domain.SettlementNetting {
+	base, quote, _ := strings.Cut(m.Corridor, "-")
+	n := &domain.SettlementNetting{
+		ID:               uuid.New(),
+		Corridor:         m.Corridor,
+		WindowStart:      start,
+		WindowEnd:        start.Add(m.Window),
+		QuoteCurrency:    domain.Currency(quote),
+		Outbound:         decimal.Zero,
+		Inbound:          decimal.Zero,
+		TransactionCount: len(txs),
+		CreatedAt:        time.Now(),
+	}
+	// paidOutBase is what the quote senders are owed in the base currency.
+	paidOutBase := decimal.Zero
+	for _, tx := range txs {
+		n.Transactions = append(n.Transactions, domain.NettedTransaction{
+			TransactionID:     tx.ID,
+			Direction:         fmt.Sprintf("%s-%s", tx.Currency, tx.ConvertedCurrency),
+			Amount:            tx.Amount,
+			Currency:          tx.Currency,
+			ExchangeRate:      tx.ExchangeRate,
+			ConvertedAmount:   tx.ConvertedAmount,
+			ConvertedCurrency: tx.ConvertedCurrency,
+		})
+		if string(tx.Currency) == base {
+			n.Outbound = n.Outbound.Add(tx.ConvertedAmount)
+		} else {
+			n.Inbound = n.Inbound.Add(tx.Amount)
+			paidOutBase = paidOutBase.Add(tx.ConvertedAmount)
+		}
+	}
+
+	n.NetPosition = n.Outbound.Sub(n.Inbound)
+	switch {
+	case n.NetPosition.IsPositive():
+		n.SettlementAmount, n.SettlementCurrency = n.NetPosition, domain.Currency(quote)
+	case n.NetPosition.IsNegative():
+		// Converted back at the rate the quote senders actually got.
+		n.SettlementAmount = n.NetPosition.Neg().Mul(paidOutBase).Div(n.Inbound).Round(2)
+		n.SettlementCurrency = domain.Currency(base)
+	default:
+		n.SettlementAmount, n.SettlementCurrency = decimal.Zero, domain.Currency(quote)
+	}
+	return n
+}
+
+// settleNet records the netting and settles the window's net position. A
+// window whose flows cancel out completes without touching the network.
+func (s *Service) settleNet(ctx context.Context, m CorridorMode, start time.Time, txs []*domain.Transaction) error {
+	n := netPosition(m, start, txs)
+	pair := m.Corridor
+	if n.NetPosition.IsNegative() {
+		pair = m.pairs()[1]
+	}
+
+	now := time.Now()
+	settlement := &domain.Settlement{
+		ID:             uuid.New(),
+		BatchReference: s.generateBatchReference(),
+		TotalAmount:    n.SettlementAmount,
+		Currency:       n.SettlementCurrency,
+		FeeAmount:      decimal.Zero,
+		FeeCurrency:    n.SettlementCurrency,
+		Status:         domain.SettlementStatusPending,
+		Metadata: domain.Metadata{
+			"corridor":        pair,
+			SettlementModeKey: string(SettlementModeNet),
+			"netting_id":      n.ID.String(),
+			"window_start":    n.WindowStart.Format(time.RFC3339),
+			"window_end":      n.WindowEnd.Format(time.RFC3339),
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	route := s.routeBatch(ctx, pair, n.SettlementAmount)
+	settlement.Network = route.Network
+	if route.RouteID != nil {
+		settlement.Metadata["route_id"] = route.RouteID.String()
+	}
+	n.SettlementID = settlement.ID
+
+	if err := s.repo.Create(ctx, settlement); err != nil {
+		return err
+	}
+	if err := s.netting.CreateNetting(ctx, n); err != nil {
+		settlement.Status = domain.SettlementStatusFailed
+		_ = s.repo.Update(ctx, settlement)
+		return err
+	}
+	txIDs := make([]uuid.UUID, len(txs))
+	for i, tx := range txs {
+		txIDs[i] = tx.ID
+	}
+	if err := s.txRepo.BatchUpdateSettlementID(ctx, txIDs, settlement.ID); err != nil {
+		settlement.Status = domain.SettlementStatusFailed
+		_ = s.repo.Update(ctx, settlement)
+		return err
+	}
+
+	s.logger.Info("Netted settlement window", map[string]interface{}{
+		"settlement_id": settlement.ID,
+		"corridor":      m.Corridor,
+		"window_start":  n.WindowStart,
+		"count":         n.TransactionCount,
+		"net_position":  n.NetPosition.String(),
+	})
+
+	if n.SettlementAmount.IsZero() {
+		s.completeSettlement(ctx, settlement, domain.Metadata{"netted": true})
+		return nil
+	}
+	return s.submit(ctx, settlement)
+}
+
+// GetNetting returns the audit of the netting a settlement was made from.
+func (s *Service) GetNetting(ctx context.Context, settlementID uuid.UUID) (*domain.SettlementNetting, error) {
+	if s.netting == nil {
+		return nil, ErrNettingNotFound
+	}
+	return s.netting.FindNettingBySettlementID(ctx, settlementID)
+}
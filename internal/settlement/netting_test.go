@@ -0,0 +1,175 @@
+package settlement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memNetting serves the given payments and keeps the nettings it is handed.
+type memNetting struct {
+	txs      []*domain.Transaction
+	nettings []*domain.SettlementNetting
+}
+
+func (m *memNetting) FindPendingSettlementExcept(ctx context.Context, pairs []string, limit int) ([]*domain.Transaction, error) {
+	return nil, nil
+}
+
+func (m *memNetting) FindNettable(ctx context.Context, pairs []string, before time.Time, limit int) ([]*domain.Transaction, error) {
+	var out []*domain.Transaction
+	for _, tx := range m.txs {
+		pair := string(tx.Currency) + "-" + string(tx.ConvertedCurrency)
+		if (pair == pairs[0] || pair == pairs[1]) && nettingTime(tx).Before(before) {
+			out = append(out, tx)
+		}
+	}
+	return out, nil
+}
+
+func (m *memNetting) CreateNetting(ctx context.Context, n *domain.SettlementNetting) error {
+	m.nettings = append(m.nettings, n)
+	return nil
+}
+
+func (m *memNetting) FindNettingBySettlementID(ctx context.Context, settlementID uuid.UUID) (*domain.SettlementNetting, error) {
+	for _, n := range m.nettings {
+		if n.SettlementID == settlementID {
+			return n, nil
+		}
+	}
+	return nil, ErrNettingNotFound
+}
+
+func netTx(from, to domain.Currency, amount, converted float64, at time.Time) *domain.Transaction {
+	return &domain.Transaction{
+		ID:                uuid.New(),
+		Amount:            decimal.NewFromFloat(amount),
+		Currency:          from,
+		ConvertedAmount:   decimal.NewFromFloat(converted),
+		ConvertedCurrency: to,
+		CompletedAt:       &at,
+	}
+}
+
+func TestParseCorridorModes(t *testing.T) {
+	modes, err := ParseCorridorModes([]string{"mwk-zar=net:1h", " MWK-CNY=gross ", ""})
+	require.NoError(t, err)
+	assert.Equal(t, []CorridorMode{
+		{Corridor: "MWK-ZAR", Mode: SettlementModeNet, Window: time.Hour},
+		{Corridor: "MWK-CNY", Mode: SettlementModeGross},
+	}, modes)
+
+	for _, bad := range []string{"MWK-ZAR", "MWK=net:1h", "MWK-MWK=gross", "MWK-ZAR=net", "MWK-ZAR=net:10s", "MWK-ZAR=batch"} {
+		_, err := ParseCorridorModes([]string{bad})
+		assert.Error(t, err, bad)
+	}
+	_, err = ParseCorridorModes([]string{"MWK-ZAR=gross", "ZAR-MWK=net:1h"})
+	assert.Error(t, err, "one corridor, two modes")
+}
+
+func TestGroupByCurrency_GrossCorridor(t *testing.T) {
+	modes, _ := ParseCorridorModes([]string{"MWK-CNY=gross"})
+	s := (&Service{}).WithCorridorModes(modes, nil)
+	gross := netTx(domain.CNY, domain.MWK, 10, 2400, time.Now())
+	batched := netTx(domain.MWK, domain.ZAR, 100, 1, time.Now())
+
+	batches := s.groupByCurrency([]*domain.Transaction{gross, batched, netTx(domain.MWK, domain.CNY, 2400, 10, time.Now()), batched})
+	assert.Len(t, batches, 3, "each gross payment settles on its own, in both directions")
+	assert.Len(t, batches[batchKey{pair: "CNY-MWK", tx: gross.ID}], 1)
+	assert.Len(t, batches[batchKey{pair: "MWK-ZAR"}], 2)
+}
+
+func TestSettledBy(t *testing.T) {
+	modes, _ := ParseCorridorModes([]string{"MWK-ZAR=net:1h", "MWK-CNY=gross"})
+	s := (&Service{}).WithCorridorModes(modes, nil)
+	at := time.Date(2024, 5, 15, 9, 10, 0, 0, time.UTC)
+
+	assert.Equal(t, at.Add(settlementInterval), s.SettledBy(domain.MWK, domain.CNY, at))
+	assert.Equal(t, at.Add(settlementInterval), s.SettledBy(domain.MWK, domain.USD, at), "batched corridors settle on the next run")
+	assert.Equal(t, time.Date(2024, 5, 15, 10, 0, 30, 0, time.UTC), s.SettledBy(domain.ZAR, domain.MWK, at), "net corridors wait for the window to close")
+}
+
+func TestNetPosition(t *testing.T) {
+	m := CorridorMode{Corridor: "MWK-ZAR", Mode: SettlementModeNet, Window: time.Hour}
+	start := time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC)
+	at := start.Add(10 * time.Minute)
+
+	// MWK senders owe 30 ZAR, ZAR senders paid in 20 ZAR: 10 ZAR to pay out.
+	n := netPosition(m, start, []*domain.Transaction{
+		netTx(domain.MWK, domain.ZAR, 1000, 10, at),
+		netTx(domain.MWK, domain.ZAR, 2000, 20, at),
+		netTx(domain.ZAR, domain.MWK, 20, 1800, at),
+	})
+	assert.True(t, n.Outbound.Equal(decimal.NewFromInt(30)))
+	assert.True(t, n.Inbound.Equal(decimal.NewFromInt(20)))
+	assert.True(t, n.NetPosition.Equal(decimal.NewFromInt(10)))
+	assert.True(t, n.SettlementAmount.Equal(decimal.NewFromInt(10)))
+	assert.Equal(t, domain.ZAR, n.SettlementCurrency)
+	assert.Equal(t, start.Add(time.Hour), n.WindowEnd)
+	assert.Len(t, n.Transactions, 3)
+
+	// ZAR senders paid in 40 ZAR for 3600 MWK; 10 ZAR of it is owed to MWK
+	// senders, so the remaining 30 ZAR goes out as 2700 MWK.
+	n = netPosition(m, start, []*domain.Transaction{
+		netTx(domain.MWK, domain.ZAR, 1000, 10, at),
+		netTx(domain.ZAR, domain.MWK, 40, 3600, at),
+	})
+	assert.True(t, n.NetPosition.Equal(decimal.NewFromInt(-30)))
+	assert.True(t, n.SettlementAmount.Equal(decimal.NewFromInt(2700)))
+	assert.Equal(t, domain.MWK, n.SettlementCurrency)
+
+	n = netPosition(m, start, []*domain.Transaction{
+		netTx(domain.MWK, domain.ZAR, 1000, 10, at),
+		netTx(domain.ZAR, domain.MWK, 10, 900, at),
+	})
+	assert.True(t, n.SettlementAmount.IsZero(), "flows that cancel out settle nothing")
+}
+
+func TestProcessNetSettlements(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 15, 10, 20, 0, 0, time.UTC)
+	closed := netTx(domain.MWK, domain.ZAR, 1000, 10, now.Add(-40*time.Minute))
+	offset := netTx(domain.ZAR, domain.MWK, 10, 900, now.Add(-30*time.Minute))
+	open := netTx(domain.MWK, domain.ZAR, 500, 5, now.Add(-10*time.Minute))
+	netting := &memNetting{txs: []*domain.Transaction{closed, offset, open}}
+
+	repo := new(MockRepository)
+	txRepo := new(MockTransactionRepository)
+	s := &Service{repo: repo, txRepo: txRepo, logger: logger.NewNop(), feePolicy: DefaultFeePolicy()}
+	modes, _ := ParseCorridorModes([]string{"MWK-ZAR=net:1h"})
+	s.WithCorridorModes(modes, netting)
+
+	var created *domain.Settlement
+	repo.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(1).(*domain.Settlement)
+	}).Return(nil)
+	repo.On("Update", ctx, mock.Anything).Return(nil)
+	txRepo.On("BatchUpdateSettlementID", ctx, []uuid.UUID{closed.ID, offset.ID}, mock.Anything).Return(nil)
+	txRepo.On("FindBySettlementID", ctx, mock.Anything).Return([]*domain.Transaction{closed, offset}, nil)
+	txRepo.On("Update", ctx, mock.Anything).Return(nil)
+
+	require.NoError(t, s.ProcessNetSettlements(ctx, now))
+
+	require.Len(t, netting.nettings, 1, "the open window waits")
+	n := netting.nettings[0]
+	assert.Equal(t, created.ID, n.SettlementID)
+	assert.Equal(t, 2, n.TransactionCount)
+	assert.Equal(t, string(SettlementModeNet), created.Metadata[SettlementModeKey])
+	assert.Equal(t, domain.SettlementStatusConfirmed, created.Status, "a fully offset window completes without the network")
+	assert.Equal(t, domain.TransactionStatusCompleted, closed.Status)
+	txRepo.AssertNumberOfCalls(t, "Update", 2)
+
+	got, err := s.GetNetting(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, n, got)
+}
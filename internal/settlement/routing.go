@@ -0,0 +1,360 @@
+package settlement
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidRoute    = errors.New("invalid settlement route")
+	ErrRouteNotFound   = errors.New("settlement route not found")
+	ErrRouteConflict   = errors.New("settlement route overlaps an existing route")
+	ErrRouteImmutable  = errors.New("settlement route is already in effect")
+	ErrUnsupportedRail = errors.New("settlement network is not configured")
+)
+
+// defaultRouteCutover is the batch total above which unrouted corridors settle on Ripple.
+var defaultRouteCutover = decimal.NewFromInt(100000)
+
+type RouteRepository interface {
+	CreateRoute(ctx context.Context, r *domain.SettlementRoute) error
+	UpdateRoute(ctx context.Context, r *domain.SettlementRoute) error
+	DeleteRoute(ctx context.Context, id uuid.UUID) error
+	GetRoute(ctx context.Context, id uuid.UUID) (*domain.SettlementRoute, error)
+	ListRoutes(ctx context.Context, corridor string) ([]*domain.SettlementRoute, error)
+	ListRouteSamples(ctx context.Context, from, to time.Time) ([]*domain.RouteSample, error)
+}
+
+// Router resolves the settlement rail for a corridor and amount from the
+// admin-maintained route table, falling back to the legacy amount cut-over
+// (large batches on Ripple, retail on Stellar) when no route matches.
+type Router struct {
+	repo     RouteRepository
+	networks map[domain.BlockchainNetwork]bool
+}
+
+func NewRouter(repo RouteRepository, networks ...domain.BlockchainNetwork) *Router {
+	r := &Router{repo: repo, networks: make(map[domain.BlockchainNetwork]bool)}
+	for _, n := range networks {
+		r.networks[n] = true
+	}
+	return r
+}
+
+// WithRouter enables table-driven corridor routing.
+func (s *Service) WithRouter(r *Router) *Service {
+	s.router = r
+	return s
+}
+
+// RouteDecision explains which rail a settlement would use and why.
+type RouteDecision struct {
+	Network domain.BlockchainNetwork `json:"network"`
+	RouteID *uuid.UUID               `json:"route_id,omitempty"`
+	Reason  string                   `json:"reason"`
+}
+
+func legacyRoute(amount decimal.Decimal) RouteDecision {
+	if amount.GreaterThan(defaultRouteCutover) {
+		return RouteDecision{Network: domain.NetworkRipple, Reason: "default: large batch"}
+	}
+	return RouteDecision{Network: domain.NetworkStellar, Reason: "default: retail batch"}
+}
+
+func routeActive(rt *domain.SettlementRoute, at time.Time) bool {
+	if rt.EffectiveFrom.After(at) {
+		return false
+	}
+	return rt.EffectiveTo == nil || at.Before(*rt.EffectiveTo)
+}
+
+func routeCovers(rt *domain.SettlementRoute, corridor string, amount decimal.Decimal) bool {
+	if rt.Corridor != "*" && !strings.EqualFold(rt.Corridor, corridor) {
+		return false
+	}
+	if amount.LessThan(rt.MinAmount) {
+		return false
+	}
+	return rt.MaxAmount == nil || amount.LessThan(*rt.MaxAmount)
+}
+
+// pickRoute chooses among routes: an exact corridor beats the wildcard, and
+// among equals the most recently effective route wins.
+func pickRoute(routes []*domain.SettlementRoute, corridor string, amount decimal.Decimal, at time.Time) RouteDecision {
+	var best *domain.SettlementRoute
+	for _, rt := range routes {
+		if !routeActive(rt, at) || !routeCovers(rt, corridor, amount) {
+			continue
+		}
+		if best == nil {
+			best = rt
+			continue
+		}
+		bestExact, exact := best.Corridor != "*", rt.Corridor != "*"
+		if exact != bestExact {
+			if exact {
+				best = rt
+			}
+			continue
+		}
+		if rt.EffectiveFrom.After(best.EffectiveFrom) {
+			best = rt
+		}
+	}
+	if best == nil {
+		return legacyRoute(amount)
+	}
+	id := best.ID
+	return RouteDecision{
+		Network: best.Network,
+		RouteID: &id,
+		Reason:  fmt.Sprintf("route %s (%s)", best.Corridor, best.EffectiveFrom.Format(time.RFC3339)),
+	}
+}
+
+// Resolve returns the rail for a corridor and amount at the given time.
+func (r *Router) Resolve(ctx context.Context, corridor string, amount decimal.Decimal, at time.Time) (RouteDecision, error) {
+	routes, err := r.repo.ListRoutes(ctx, "")
+	if err != nil {
+		return RouteDecision{}, err
+	}
+	return pickRoute(routes, corridor, amount, at), nil
+}
+
+func (r *Router) validate(rt *domain.SettlementRoute) error {
+	rt.Corridor = strings.ToUpper(strings.TrimSpace(rt.Corridor))
+	if rt.Corridor == "" {
+		return fmt.Errorf("%w: corridor is required", ErrInvalidRoute)
+	}
+	if rt.Corridor != "*" {
+		parts := strings.Split(rt.Corridor, "-")
+		if len(parts) != 2 || len(parts[0]) != 3 || len(parts[1]) != 3 {
+			return fmt.Errorf("%w: corridor must look like MWK-CNY", ErrInvalidRoute)
+		}
+	}
+	if !r.networks[rt.Network] {
+		return fmt.Errorf("%w: %s", ErrUnsupportedRail, rt.Network)
+	}
+	if rt.MinAmount.IsNegative() {
+		return fmt.Errorf("%w: min_amount must not be negative", ErrInvalidRoute)
+	}
+	if rt.MaxAmount != nil && !rt.MaxAmount.GreaterThan(rt.MinAmount) {
+		return fmt.Errorf("%w: max_amount must be greater than min_amount", ErrInvalidRoute)
+	}
+	if rt.EffectiveFrom.IsZero() {
+		return fmt.Errorf("%w: effective_from is required", ErrInvalidRoute)
+	}
+	if rt.EffectiveTo != nil && !rt.EffectiveTo.After(rt.EffectiveFrom) {
+		return fmt.Errorf("%w: effective_to must be after effective_from", ErrInvalidRoute)
+	}
+	return nil
+}
+
+func bandsOverlap(a, b *domain.SettlementRoute) bool {
+	if a.MaxAmount != nil && !a.MaxAmount.GreaterThan(b.MinAmount) {
+		return false
+	}
+	if b.MaxAmount != nil && !b.MaxAmount.GreaterThan(a.MinAmount) {
+		return false
+	}
+	return true
+}
+
+// checkConflicts rejects a route that shares corridor, amount band and
+// effective date with another route, since neither would win deterministically.
+func (r *Router) checkConflicts(ctx context.Context, rt *domain.SettlementRoute) error {
+	existing, err := r.repo.ListRoutes(ctx, rt.Corridor)
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.ID == rt.ID || other.Corridor != rt.Corridor {
+			continue
+		}
+		if other.EffectiveFrom.Equal(rt.EffectiveFrom) && bandsOverlap(other, rt) {
+			return fmt.Errorf("%w: %s", ErrRouteConflict, other.ID)
+		}
+	}
+	return nil
+}
+
+func (r *Router) CreateRoute(ctx context.Context, rt *domain.SettlementRoute) (*domain.SettlementRoute, error) {
+	if err := r.validate(rt); err != nil {
+		return nil, err
+	}
+	if rt.ID == uuid.Nil {
+		rt.ID = uuid.New()
+	}
+	if err := r.checkConflicts(ctx, rt); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rt.CreatedAt = now
+	rt.UpdatedAt = now
+	if err := r.repo.CreateRoute(ctx, rt); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// UpdateRoute edits a scheduled route. Routes already in effect keep their
+// mapping for auditability; only their end date can be changed, and a new
+// route should be scheduled to switch rails.
+func (r *Router) UpdateRoute(ctx context.Context, updated *domain.SettlementRoute) (*domain.SettlementRoute, error) {
+	existing, err := r.repo.GetRoute(ctx, updated.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !existing.EffectiveFrom.After(time.Now()) {
+		sameMapping := strings.EqualFold(existing.Corridor, strings.TrimSpace(updated.Corridor)) &&
+			existing.Network == updated.Network &&
+			existing.MinAmount.Equal(updated.MinAmount) &&
+			existing.EffectiveFrom.Equal(updated.EffectiveFrom) &&
+			decimalPtrEqual(existing.MaxAmount, updated.MaxAmount)
+		if !sameMapping {
+			return nil, ErrRouteImmutable
+		}
+	}
+	if err := r.validate(updated); err != nil {
+		return nil, err
+	}
+	if err := r.checkConflicts(ctx, updated); err != nil {
+		return nil, err
+	}
+	updated.CreatedAt = existing.CreatedAt
+	updated.CreatedBy = existing.CreatedBy
+	updated.UpdatedAt = time.Now()
+	if err := r.repo.UpdateRoute(ctx, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteRoute removes a route that has not yet taken effect.
+func (r *Router) DeleteRoute(ctx context.Context, id uuid.UUID) error {
+	existing, err := r.repo.GetRoute(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !existing.EffectiveFrom.After(time.Now()) {
+		return ErrRouteImmutable
+	}
+	return r.repo.DeleteRoute(ctx, id)
+}
+
+func (r *Router) GetRoute(ctx context.Context, id uuid.UUID) (*domain.SettlementRoute, error) {
+	return r.repo.GetRoute(ctx, id)
+}
+
+func (r *Router) ListRoutes(ctx context.Context, corridor string) ([]*domain.SettlementRoute, error) {
+	return r.repo.ListRoutes(ctx, strings.ToUpper(strings.TrimSpace(corridor)))
+}
+
+func decimalPtrEqual(a, b *decimal.Decimal) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// RouteSimulationRow aggregates simulated volume per corridor and rail.
+type RouteSimulationRow struct {
+	Corridor string                   `json:"corridor"`
+	Network  domain.BlockchainNetwork `json:"network"`
+	Count    int                      `json:"count"`
+	Volume   decimal.Decimal          `json:"volume"`
+	// Changed counts transactions that settled on a different rail than simulated.
+	Changed int `json:"changed"`
+}
+
+type RouteSimulation struct {
+	From         time.Time             `json:"from"`
+	To           time.Time             `json:"to"`
+	Transactions int                   `json:"transactions"`
+	Changed      int                   `json:"changed"`
+	Rows         []*RouteSimulationRow `json:"rows"`
+}
+
+// Simulate replays the transactions created in [from, to) through a route set
+// and reports where their volume would have settled. Routes are evaluated as of
+// at, or as of each transaction's creation time when at is zero. When draft is
+// nil the stored routes are used.
+func (r *Router) Simulate(ctx context.Context, from, to, at time.Time, draft []*domain.SettlementRoute) (*RouteSimulation, error) {
+	routes := draft
+	if routes == nil {
+		var err error
+		if routes, err = r.repo.ListRoutes(ctx, ""); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, rt := range routes {
+			if err := r.validate(rt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	samples, err := r.repo.ListRouteSamples(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &RouteSimulation{From: from, To: to, Rows: []*RouteSimulationRow{}}
+	rows := make(map[string]*RouteSimulationRow)
+	for _, smp := range samples {
+		corridor := fmt.Sprintf("%s-%s", smp.Currency, smp.ConvertedCurrency)
+		when := at
+		if when.IsZero() {
+			when = smp.CreatedAt
+		}
+		decision := pickRoute(routes, corridor, smp.ConvertedAmount, when)
+
+		key := corridor + "|" + string(decision.Network)
+		row, ok := rows[key]
+		if !ok {
+			row = &RouteSimulationRow{Corridor: corridor, Network: decision.Network, Volume: decimal.Zero}
+			rows[key] = row
+			out.Rows = append(out.Rows, row)
+		}
+		row.Count++
+		row.Volume = row.Volume.Add(smp.ConvertedAmount)
+		out.Transactions++
+		if smp.ActualNetwork != nil && *smp.ActualNetwork != decision.Network {
+			row.Changed++
+			out.Changed++
+		}
+	}
+
+	sort.Slice(out.Rows, func(i, j int) bool {
+		if out.Rows[i].Corridor != out.Rows[j].Corridor {
+			return out.Rows[i].Corridor < out.Rows[j].Corridor
+		}
+		return out.Rows[i].Network < out.Rows[j].Network
+	})
+	return out, nil
+}
+
+// routeBatch picks the rail for a new batch.
+func (s *Service) routeBatch(ctx context.Context, corridor string, amount decimal.Decimal) RouteDecision {
+	if s.router == nil {
+		return legacyRoute(amount)
+	}
+	decision, err := s.router.Resolve(ctx, corridor, amount, time.Now())
+	if err != nil {
+		s.logger.Warn("Route lookup failed, using default routing", map[string]interface{}{
+			"corridor": corridor,
+			"error":    err.Error(),
+		})
+		return legacyRoute(amount)
+	}
+	return decision
+}
@@ -0,0 +1,186 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRouteRepository struct {
+	mock.Mock
+}
+
+func (m *MockRouteRepository) CreateRoute(ctx context.Context, r *domain.SettlementRoute) error {
+	return m.Called(ctx, r).Error(0)
+}
+
+func (m *MockRouteRepository) UpdateRoute(ctx context.Context, r *domain.SettlementRoute) error {
+	return m.Called(ctx, r).Error(0)
+}
+
+func (m *MockRouteRepository) DeleteRoute(ctx context.Context, id uuid.UUID) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockRouteRepository) GetRoute(ctx context.Context, id uuid.UUID) (*domain.SettlementRoute, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SettlementRoute), args.Error(1)
+}
+
+func (m *MockRouteRepository) ListRoutes(ctx context.Context, corridor string) ([]*domain.SettlementRoute, error) {
+	args := m.Called(ctx, corridor)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.SettlementRoute), args.Error(1)
+}
+
+func (m *MockRouteRepository) ListRouteSamples(ctx context.Context, from, to time.Time) ([]*domain.RouteSample, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.RouteSample), args.Error(1)
+}
+
+func testRoute(corridor string, network domain.BlockchainNetwork, from time.Time) *domain.SettlementRoute {
+	return &domain.SettlementRoute{
+		ID:            uuid.New(),
+		Corridor:      corridor,
+		MinAmount:     decimal.Zero,
+		Network:       network,
+		EffectiveFrom: from,
+	}
+}
+
+func TestPickRoute_Precedence(t *testing.T) {
+	now := time.Now()
+	wildcard := testRoute("*", domain.NetworkRipple, now.Add(-48*time.Hour))
+	exact := testRoute("MWK-CNY", domain.NetworkStellar, now.Add(-72*time.Hour))
+	routes := []*domain.SettlementRoute{wildcard, exact}
+
+	d := pickRoute(routes, "MWK-CNY", decimal.NewFromInt(10), now)
+	assert.Equal(t, domain.NetworkStellar, d.Network)
+	assert.Equal(t, exact.ID, *d.RouteID)
+
+	d = pickRoute(routes, "ZMW-CNY", decimal.NewFromInt(10), now)
+	assert.Equal(t, domain.NetworkRipple, d.Network)
+
+	// No routes falls back to the amount cut-over.
+	d = pickRoute(nil, "MWK-CNY", decimal.NewFromInt(200000), now)
+	assert.Equal(t, domain.NetworkRipple, d.Network)
+	assert.Nil(t, d.RouteID)
+}
+
+func TestPickRoute_AmountBandAndSwitchover(t *testing.T) {
+	now := time.Now()
+	limit := decimal.NewFromInt(5000)
+	small := testRoute("MWK-CNY", domain.NetworkStellar, now.Add(-time.Hour))
+	small.MaxAmount = &limit
+	large := testRoute("MWK-CNY", domain.NetworkRipple, now.Add(-time.Hour))
+	large.MinAmount = limit
+	switchover := testRoute("MWK-CNY", domain.NetworkRipple, now.Add(time.Hour))
+	switchover.MaxAmount = &limit
+	routes := []*domain.SettlementRoute{small, large, switchover}
+
+	assert.Equal(t, domain.NetworkStellar, pickRoute(routes, "MWK-CNY", decimal.NewFromInt(4999), now).Network)
+	assert.Equal(t, domain.NetworkRipple, pickRoute(routes, "MWK-CNY", decimal.NewFromInt(5000), now).Network)
+
+	// After the scheduled switchover small batches move to Ripple.
+	later := now.Add(2 * time.Hour)
+	assert.Equal(t, domain.NetworkRipple, pickRoute(routes, "MWK-CNY", decimal.NewFromInt(10), later).Network)
+}
+
+func TestRouter_CreateRouteValidation(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRouteRepository)
+	router := NewRouter(repo, domain.NetworkStellar, domain.NetworkRipple)
+
+	_, err := router.CreateRoute(ctx, testRoute("MWKCNY", domain.NetworkStellar, time.Now()))
+	assert.True(t, errors.Is(err, ErrInvalidRoute))
+
+	_, err = router.CreateRoute(ctx, testRoute("MWK-CNY", domain.BlockchainNetwork("ethereum"), time.Now()))
+	assert.True(t, errors.Is(err, ErrUnsupportedRail))
+
+	from := time.Now().Add(time.Hour)
+	existing := testRoute("MWK-CNY", domain.NetworkStellar, from)
+	repo.On("ListRoutes", ctx, "MWK-CNY").Return([]*domain.SettlementRoute{existing}, nil)
+
+	_, err = router.CreateRoute(ctx, testRoute("mwk-cny", domain.NetworkRipple, from))
+	assert.True(t, errors.Is(err, ErrRouteConflict))
+
+	repo.On("CreateRoute", ctx, mock.Anything).Return(nil).Once()
+	created, err := router.CreateRoute(ctx, testRoute("MWK-CNY", domain.NetworkRipple, from.Add(time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, "MWK-CNY", created.Corridor)
+	repo.AssertExpectations(t)
+}
+
+func TestRouter_ActiveRouteIsImmutable(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRouteRepository)
+	router := NewRouter(repo, domain.NetworkStellar, domain.NetworkRipple)
+
+	active := testRoute("MWK-CNY", domain.NetworkStellar, time.Now().Add(-time.Hour))
+	repo.On("GetRoute", ctx, active.ID).Return(active, nil)
+
+	changed := *active
+	changed.Network = domain.NetworkRipple
+	_, err := router.UpdateRoute(ctx, &changed)
+	assert.Equal(t, ErrRouteImmutable, err)
+
+	assert.Equal(t, ErrRouteImmutable, router.DeleteRoute(ctx, active.ID))
+
+	// Ending an active route is allowed.
+	end := time.Now().Add(time.Hour)
+	closing := *active
+	closing.EffectiveTo = &end
+	repo.On("ListRoutes", ctx, "MWK-CNY").Return([]*domain.SettlementRoute{active}, nil)
+	repo.On("UpdateRoute", ctx, mock.Anything).Return(nil).Once()
+	_, err = router.UpdateRoute(ctx, &closing)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestRouter_Simulate(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRouteRepository)
+	router := NewRouter(repo, domain.NetworkStellar, domain.NetworkRipple)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	stellar, ripple := domain.NetworkStellar, domain.NetworkRipple
+	repo.On("ListRouteSamples", ctx, from, to).Return([]*domain.RouteSample{
+		{Currency: domain.MWK, ConvertedCurrency: domain.CNY, ConvertedAmount: decimal.NewFromInt(100), ActualNetwork: &stellar, CreatedAt: from.Add(time.Hour)},
+		{Currency: domain.MWK, ConvertedCurrency: domain.CNY, ConvertedAmount: decimal.NewFromInt(300), ActualNetwork: &stellar, CreatedAt: from.Add(2 * time.Hour)},
+		{Currency: domain.MWK, ConvertedCurrency: domain.CNY, ConvertedAmount: decimal.NewFromInt(200000), ActualNetwork: &ripple, CreatedAt: from.Add(3 * time.Hour)},
+	}, nil)
+
+	// Stored routing is empty, so everything follows the legacy cut-over.
+	repo.On("ListRoutes", ctx, "").Return([]*domain.SettlementRoute{}, nil).Once()
+	sim, err := router.Simulate(ctx, from, to, time.Time{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sim.Transactions)
+	assert.Equal(t, 0, sim.Changed)
+	assert.Len(t, sim.Rows, 2)
+
+	// A draft moving the whole corridor to Ripple changes the two retail transactions.
+	draft := []*domain.SettlementRoute{testRoute("MWK-CNY", domain.NetworkRipple, from)}
+	sim, err = router.Simulate(ctx, from, to, time.Time{}, draft)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sim.Changed)
+	assert.Len(t, sim.Rows, 1)
+	assert.True(t, sim.Rows[0].Volume.Equal(decimal.NewFromInt(200400)))
+	repo.AssertExpectations(t)
+}
@@ -23,6 +23,16 @@ type Service struct {
 	rippleConnector  BlockchainConnector
 	logger           logger.Logger
 	monitorInterval  time.Duration
+	feePolicy        FeePolicy
+	throttle         *Throttle
+	router           *Router
+	events           EventRecorder
+	maintenance      MaintenanceChecker
+	freezes          FreezeChecker
+	jurisdictions    JurisdictionChecker
+	modes            map[string]CorridorMode
+	netting          NettingRepository
+	counterparties   CounterpartyGate
 }
 
 func NewService(
@@ -38,6 +48,7 @@ func NewService(
 		rippleConnector:  ripple,
 		logger:           log,
 		monitorInterval:  2 * time.Second,
+		feePolicy:        DefaultFeePolicy(),
 	}
 
 	// Start settlement worker
@@ -56,17 +67,29 @@ func (s *Service) startSettlementWorker() {
 		})
 	}
 
-	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds instead of 1 hour
+	ticker := time.NewTicker(settlementInterval) // Check every 30 seconds instead of 1 hour
 	defer ticker.Stop()
 
 	for range ticker.C {
+		paused := s.submissionPaused(ctx)
+		if paused {
+			s.logger.Info("Settlement submission paused for maintenance", nil)
+		}
+
 		// 1. Process batches of pending transactions
-		if err := s.ProcessPendingSettlements(ctx); err != nil {
+		if err := s.ProcessPendingSettlements(ctx); err != nil && err != ErrSubmissionPaused {
 			s.logger.Error("Settlement worker error", map[string]interface{}{
 				"error": err.Error(),
 			})
 		}
 
+		// 1b. Retry settlements held back by the fee policy
+		if err := s.ProcessDeferredSettlements(ctx); err != nil && err != ErrSubmissionPaused {
+			s.logger.Error("Deferred settlement worker error", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
 		// 2. Check status of submitted settlements on blockchain
 		if err := s.RecoverPendingSettlements(ctx); err != nil {
 			s.logger.Error("Recovery worker error", map[string]interface{}{
@@ -81,13 +104,6 @@ func (s *Service) startSettlementWorker() {
 				go s.monitorSettlement(set.ID, set.TransactionHash)
 			}
 		}
-
-		// 4. Cleanup old stuck transactions
-		if err := s.CleanupStuckTransactions(ctx); err != nil {
-			s.logger.Error("Cleanup worker error", map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
 	}
 }
 
@@ -110,48 +126,22 @@ func (s *Service) RecoverPendingSettlements(ctx context.Context) error {
 	return nil
 }
 
-// CleanupStuckTransactions finds and fails transactions stuck in pending state
-func (s *Service) CleanupStuckTransactions(ctx context.Context) error {
-	// Find transactions pending for more than 1 hour
-	stuckTxs, err := s.txRepo.FindStuckPending(ctx, 1*time.Hour, 100)
-	if err != nil {
-		return err
+// ProcessPendingSettlements batches and settles pending transactions
+func (s *Service) ProcessPendingSettlements(ctx context.Context) error {
+	if s.submissionPaused(ctx) {
+		return ErrSubmissionPaused
 	}
+	s.logger.Info("Processing pending settlements", nil)
 
-	if len(stuckTxs) > 0 {
-		s.logger.Info("Found stuck transactions", map[string]interface{}{
-			"count": len(stuckTxs),
+	// Net corridors settle whichever windows have closed
+	if err := s.ProcessNetSettlements(ctx, time.Now()); err != nil {
+		s.logger.Error("Net settlement worker error", map[string]interface{}{
+			"error": err.Error(),
 		})
 	}
 
-	for _, tx := range stuckTxs {
-		tx.Status = domain.TransactionStatusFailed
-		reason := "Timeout: Transaction stuck in pending state"
-		tx.StatusReason = reason
-		now := time.Now()
-		tx.CompletedAt = &now
-		tx.UpdatedAt = now
-
-		if err := s.txRepo.Update(ctx, tx); err != nil {
-			s.logger.Error("Failed to mark stuck transaction as failed", map[string]interface{}{
-				"tx_id": tx.ID,
-				"error": err.Error(),
-			})
-		} else {
-			s.logger.Info("Marked stuck transaction as failed", map[string]interface{}{
-				"tx_id": tx.ID,
-			})
-		}
-	}
-	return nil
-}
-
-// ProcessPendingSettlements batches and settles pending transactions
-func (s *Service) ProcessPendingSettlements(ctx context.Context) error {
-	s.logger.Info("Processing pending settlements", nil)
-
 	// Get pending transactions
-	pendingTxs, err := s.txRepo.FindPendingSettlement(ctx, 100)
+	pendingTxs, err := s.pendingSettlements(ctx, 100)
 	if err != nil {
 		return err
 	}
@@ -161,15 +151,16 @@ func (s *Service) ProcessPendingSettlements(ctx context.Context) error {
 		return nil
 	}
 
-	// Group by currency pair
+	// Group by currency pair and settlement lane
 	batches := s.groupByCurrency(pendingTxs)
 
-	for pair, txs := range batches {
-		if err := s.settleBatch(ctx, pair, txs); err != nil {
+	for key, txs := range batches {
+		if err := s.settleBatch(ctx, key, txs); err != nil {
 			s.logger.Error("Batch settlement failed", map[string]interface{}{
-				"pair":  pair,
-				"count": len(txs),
-				"error": err.Error(),
+				"pair":     key.pair,
+				"priority": key.priority,
+				"count":    len(txs),
+				"error":    err.Error(),
 			})
 			continue
 		}
@@ -178,7 +169,9 @@ func (s *Service) ProcessPendingSettlements(ctx context.Context) error {
 	return nil
 }
 
-func (s *Service) settleBatch(ctx context.Context, pair string, txs []*domain.Transaction) error {
+func (s *Service) settleBatch(ctx context.Context, key batchKey, txs []*domain.Transaction) error {
+	pair := key.pair
+
 	// Calculate total amount
 	totalAmount := decimal.Zero
 	for _, tx := range txs {
@@ -194,18 +187,24 @@ func (s *Service) settleBatch(ctx context.Context, pair string, txs []*domain.Tr
 		FeeAmount:      decimal.Zero,
 		FeeCurrency:    txs[0].ConvertedCurrency,
 		Status:         domain.SettlementStatusPending,
-		Metadata:       make(domain.Metadata),
+		Metadata:       domain.Metadata{"corridor": pair},
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
+	if key.tx != uuid.Nil {
+		settlement.Metadata[SettlementModeKey] = string(SettlementModeGross)
+	}
+	if key.priority {
+		// Priority lane batches always bid for fast inclusion.
+		settlement.Metadata[domain.SettlementLaneKey] = domain.SettlementLanePriority
+		settlement.Metadata["priority"] = PriorityHigh
+	}
 
-	// Determine network based on transaction volume
-	if totalAmount.GreaterThan(decimal.NewFromInt(100000)) {
-		// Large B2B transactions -> Ripple
-		settlement.Network = domain.NetworkRipple
-	} else {
-		// Retail transactions -> Stellar
-		settlement.Network = domain.NetworkStellar
+	// Determine network from the corridor routing table
+	route := s.routeBatch(ctx, pair, totalAmount)
+	settlement.Network = route.Network
+	if route.RouteID != nil {
+		settlement.Metadata["route_id"] = route.RouteID.String()
 	}
 
 	// Store settlement
@@ -236,40 +235,94 @@ func (s *Service) settleBatch(ctx context.Context, pair string, txs []*domain.Tr
 		return err
 	}
 
-	// Execute blockchain settlement
-	var connector BlockchainConnector
-	if settlement.Network == domain.NetworkStellar {
-		connector = s.stellarConnector
-	} else {
-		connector = s.rippleConnector
+	return s.submit(ctx, settlement)
+}
+
+func (s *Service) connectorFor(network domain.BlockchainNetwork) BlockchainConnector {
+	if network == domain.NetworkRipple {
+		return s.rippleConnector
+	}
+	return s.stellarConnector
+}
+
+// submit prices the settlement, defers it if the fee policy says so, and
+// otherwise sends it to the network and starts confirmation monitoring.
+func (s *Service) submit(ctx context.Context, settlement *domain.Settlement) error {
+	if reason := s.frozen(ctx, settlement); reason != "" {
+		return s.deferSettlement(ctx, settlement, &FeeEstimate{DeferReason: reason})
+	}
+	if reason := s.restricted(ctx, settlement); reason != "" {
+		return s.deferSettlement(ctx, settlement, &FeeEstimate{DeferReason: reason})
+	}
+	if reason := s.admit(ctx, settlement); reason != "" {
+		return s.deferSettlement(ctx, settlement, &FeeEstimate{DeferReason: reason})
+	}
+	connector := s.connectorFor(settlement.Network)
+
+	est, err := s.estimateFee(ctx, settlement, connector)
+	if err != nil {
+		return err
+	}
+	if est.DeferReason != "" {
+		return s.deferSettlement(ctx, settlement, est)
+	}
+	reason, err := s.admitCounterparty(ctx, settlement)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return s.deferSettlement(ctx, settlement, &FeeEstimate{DeferReason: reason})
 	}
+	// Connectors read the fee bid from the settlement.
+	settlement.NetworkFee = est.Fee
 
+	started := time.Now()
 	result, err := connector.SubmitSettlement(ctx, settlement)
+	if s.throttle != nil {
+		s.throttle.ObserveSubmission(settlement.Network, time.Since(started), err)
+	}
 	if err != nil {
+		s.returnCounterparty(ctx, settlement)
 		settlement.Status = domain.SettlementStatusFailed
+		settlement.UpdatedAt = time.Now()
 		_ = s.repo.Update(ctx, settlement)
 		return err
 	}
 
 	// Update settlement with blockchain info
+	applyFee(settlement, est, result)
 	settlement.TransactionHash = result.TxHash
 	settlement.Status = domain.SettlementStatusSubmitted
 	settlement.SubmissionCount++
 	now := time.Now()
 	settlement.LastSubmittedAt = &now
+	settlement.UpdatedAt = now
 
 	if err := s.repo.Update(ctx, settlement); err != nil {
 		return err
 	}
 
+	if s.events != nil {
+		if txs, err := s.txRepo.FindBySettlementID(ctx, settlement.ID); err == nil {
+			s.recordEvent(ctx, txs, domain.TransactionEventSubmitted, domain.Metadata{
+				"network": string(settlement.Network),
+				"tx_hash": result.TxHash,
+			})
+		}
+	}
+
 	// Monitor confirmation
-	go s.monitorSettlement(settlement.ID, result.TxHash)
+	if result.TxHash != "" {
+		go s.monitorSettlement(settlement.ID, result.TxHash)
+	}
 
 	s.logger.Info("Settlement submitted", map[string]interface{}{
 		"settlement_id": settlement.ID,
 		"tx_hash":       result.TxHash,
 		"network":       settlement.Network,
-		"amount":        totalAmount.String(),
+		"amount":        settlement.TotalAmount.String(),
+		"fee_strategy":  est.Strategy,
+		"network_fee":   settlement.NetworkFee.String(),
 	})
 
 	return nil
@@ -305,26 +358,7 @@ func (s *Service) monitorSettlement(settlementID uuid.UUID, txHash string) {
 		}
 
 		if confirmed {
-			now := time.Now()
-			settlement.Status = domain.SettlementStatusConfirmed
-			settlement.ConfirmedAt = &now
-			settlement.CompletedAt = &now
-
-			if err := s.repo.Update(ctx, settlement); err != nil {
-				s.logger.Error("Failed to update settlement", map[string]interface{}{
-					"settlement_id": settlementID,
-					"error":         err.Error(),
-				})
-			}
-
-			// Update all associated transactions
-			txs, _ := s.txRepo.FindBySettlementID(ctx, settlementID)
-			for _, tx := range txs {
-				tx.Status = domain.TransactionStatusCompleted
-				tx.CompletedAt = &now
-				_ = s.txRepo.Update(ctx, tx)
-			}
-
+			s.completeSettlement(ctx, settlement, domain.Metadata{"tx_hash": txHash})
 			s.logger.Info("Settlement confirmed", map[string]interface{}{
 				"settlement_id": settlementID,
 				"tx_hash":       txHash,
@@ -340,11 +374,51 @@ func (s *Service) monitorSettlement(settlementID uuid.UUID, txHash string) {
 	})
 }
 
-func (s *Service) groupByCurrency(txs []*domain.Transaction) map[string][]*domain.Transaction {
-	groups := make(map[string][]*domain.Transaction)
+// completeSettlement confirms a settlement and completes its transactions.
+func (s *Service) completeSettlement(ctx context.Context, settlement *domain.Settlement, details domain.Metadata) {
+	now := time.Now()
+	settlement.Status = domain.SettlementStatusConfirmed
+	settlement.ConfirmedAt = &now
+	settlement.CompletedAt = &now
+
+	if err := s.repo.Update(ctx, settlement); err != nil {
+		s.logger.Error("Failed to update settlement", map[string]interface{}{
+			"settlement_id": settlement.ID,
+			"error":         err.Error(),
+		})
+	}
 
+	// Update all associated transactions
+	txs, _ := s.txRepo.FindBySettlementID(ctx, settlement.ID)
 	for _, tx := range txs {
-		key := fmt.Sprintf("%s-%s", tx.Currency, tx.ConvertedCurrency)
+		tx.Status = domain.TransactionStatusCompleted
+		tx.CompletedAt = &now
+		_ = s.txRepo.Update(ctx, tx)
+	}
+	s.recordEvent(ctx, txs, domain.TransactionEventConfirmed, details)
+	s.recordEvent(ctx, txs, domain.TransactionEventDelivered, nil)
+}
+
+// batchKey identifies a settlement batch: a currency pair and whether it
+// is the priority lane, which is settled apart from standard payments. tx
+// is set for gross corridors, which settle each payment on its own.
+type batchKey struct {
+	pair     string
+	priority bool
+	tx       uuid.UUID
+}
+
+func (s *Service) groupByCurrency(txs []*domain.Transaction) map[batchKey][]*domain.Transaction {
+	groups := make(map[batchKey][]*domain.Transaction)
+
+	for _, tx := range txs {
+		key := batchKey{
+			pair:     fmt.Sprintf("%s-%s", tx.Currency, tx.ConvertedCurrency),
+			priority: tx.Metadata[domain.SettlementLaneKey] == domain.SettlementLanePriority,
+		}
+		if s.modeFor(key.pair) == SettlementModeGross {
+			key.tx = tx.ID
+		}
 		groups[key] = append(groups[key], tx)
 	}
 
@@ -379,6 +453,11 @@ func (s *Service) ListSettlementsFiltered(ctx context.Context, limit, offset int
 	return settlements, total, nil
 }
 
+// CountByStatus returns how many settlements are in status.
+func (s *Service) CountByStatus(ctx context.Context, status domain.SettlementStatus) (int, error) {
+	return s.repo.CountAllWithFilters(ctx, string(status), "", "")
+}
+
 func (s *Service) GetSettlementByID(ctx context.Context, id uuid.UUID) (*domain.Settlement, error) {
 	return s.repo.FindByID(ctx, id)
 }
@@ -394,35 +473,13 @@ func (s *Service) RetrySettlement(ctx context.Context, id uuid.UUID) (*domain.Se
 	case domain.SettlementStatusCompleted, domain.SettlementStatusReconciled:
 		return set, nil
 	}
-
-	conn := s.stellarConnector
-	if string(set.Network) == string(domain.NetworkRipple) || string(set.Network) == "ripple" {
-		conn = s.rippleConnector
-	}
-	if string(set.Network) == string(domain.NetworkStellar) || string(set.Network) == "stellar" {
-		conn = s.stellarConnector
+	if s.submissionPaused(ctx) {
+		return nil, ErrSubmissionPaused
 	}
 
-	res, err := conn.SubmitSettlement(ctx, set)
-	if err != nil {
-		set.Status = domain.SettlementStatusFailed
-		set.UpdatedAt = time.Now()
-		_ = s.repo.Update(ctx, set)
+	if err := s.submit(ctx, set); err != nil {
 		return nil, err
 	}
-
-	now := time.Now()
-	set.TransactionHash = res.TxHash
-	set.Status = domain.SettlementStatusSubmitted
-	set.SubmissionCount++
-	set.LastSubmittedAt = &now
-	set.UpdatedAt = now
-	if err := s.repo.Update(ctx, set); err != nil {
-		return nil, err
-	}
-	if set.TransactionHash != "" {
-		go s.monitorSettlement(set.ID, set.TransactionHash)
-	}
 	return set, nil
 }
 
@@ -451,13 +508,13 @@ type Repository interface {
 	CountAll(ctx context.Context) (int, error)
 	FindAllWithFilters(ctx context.Context, limit, offset int, status string, currency string, network string) ([]*domain.Settlement, error)
 	CountAllWithFilters(ctx context.Context, status string, currency string, network string) (int, error)
+	SummarizeNetworkFees(ctx context.Context, from, to time.Time) ([]*domain.NetworkFeeSummary, error)
 }
 
 type TransactionRepository interface {
 	Update(ctx context.Context, tx *domain.Transaction) error
 	FindPendingSettlement(ctx context.Context, limit int) ([]*domain.Transaction, error)
 	FindBySettlementID(ctx context.Context, settlementID uuid.UUID) ([]*domain.Transaction, error)
-	FindStuckPending(ctx context.Context, olderThan time.Duration, limit int) ([]*domain.Transaction, error)
 	BatchUpdateSettlementID(ctx context.Context, txIDs []uuid.UUID, settlementID uuid.UUID) error
 }
 
@@ -470,4 +527,6 @@ type SettlementResult struct {
 	TxHash      string
 	Confirmed   bool
 	BlockNumber int64
+	// Fee is the network fee actually charged, in the settlement currency.
+	Fee decimal.Decimal
 }
@@ -71,6 +71,14 @@ func (m *MockRepository) CountAllWithFilters(ctx context.Context, status string,
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockRepository) SummarizeNetworkFees(ctx context.Context, from, to time.Time) ([]*domain.NetworkFeeSummary, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.NetworkFeeSummary), args.Error(1)
+}
+
 type MockTransactionRepository struct {
 	mock.Mock
 }
@@ -96,14 +104,6 @@ func (m *MockTransactionRepository) FindBySettlementID(ctx context.Context, sett
 	return args.Get(0).([]*domain.Transaction), args.Error(1)
 }
 
-func (m *MockTransactionRepository) FindStuckPending(ctx context.Context, olderThan time.Duration, limit int) ([]*domain.Transaction, error) {
-	args := m.Called(ctx, olderThan, limit)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]*domain.Transaction), args.Error(1)
-}
-
 func (m *MockTransactionRepository) BatchUpdateSettlementID(ctx context.Context, txIDs []uuid.UUID, settlementID uuid.UUID) error {
 	args := m.Called(ctx, txIDs, settlementID)
 	return args.Error(0)
@@ -205,3 +205,19 @@ func TestRecoverPendingSettlements(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 	mockRipple.AssertExpectations(t)
 }
+
+func TestGroupByCurrency_PriorityLane(t *testing.T) {
+	service := &Service{}
+	standard := &domain.Transaction{Currency: domain.MWK, ConvertedCurrency: domain.CNY}
+	priority := &domain.Transaction{
+		Currency:          domain.MWK,
+		ConvertedCurrency: domain.CNY,
+		Metadata:          domain.Metadata{domain.SettlementLaneKey: domain.SettlementLanePriority},
+	}
+
+	batches := service.groupByCurrency([]*domain.Transaction{standard, priority, standard})
+
+	assert.Len(t, batches, 2, "priority payments settle in their own batch")
+	assert.Len(t, batches[batchKey{pair: "MWK-CNY"}], 2)
+	assert.Len(t, batches[batchKey{pair: "MWK-CNY", priority: true}], 1)
+}
@@ -0,0 +1,333 @@
+package settlement
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+)
+
+type ThrottleState string
+
+const (
+	ThrottleNormal ThrottleState = "normal"
+	ThrottleSlowed ThrottleState = "slowed"
+	ThrottlePaused ThrottleState = "paused"
+)
+
+// HealthMonitor reports the operator-maintained status of a network
+// (healthy, degraded, down). Satisfied by blockchain.Service.
+type HealthMonitor interface {
+	GetNetwork(ctx context.Context, id string) (*domain.BlockchainNetworkInfo, error)
+}
+
+type ThrottleConfig struct {
+	SlowLatency     time.Duration
+	PauseLatency    time.Duration
+	SlowCongestion  float64
+	PauseCongestion float64
+	// MaxFailures consecutive submission errors pause the network.
+	MaxFailures int
+	// SlowInterval is the minimum gap between submissions on a slowed network.
+	SlowInterval time.Duration
+	// SignalTTL is how long a latency, congestion or failure observation counts.
+	// Once signals expire a paused network is probed again.
+	SignalTTL time.Duration
+	HealthTTL time.Duration
+	// RerouteCorridors lists currency pairs (e.g. "MWK-CNY") that may move to the
+	// alternate rail while their network is paused; "*" allows every corridor.
+	RerouteCorridors []string
+}
+
+func DefaultThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		SlowLatency:      5 * time.Second,
+		PauseLatency:     30 * time.Second,
+		SlowCongestion:   0.7,
+		PauseCongestion:  0.95,
+		MaxFailures:      3,
+		SlowInterval:     2 * time.Minute,
+		SignalTTL:        5 * time.Minute,
+		HealthTTL:        30 * time.Second,
+		RerouteCorridors: []string{"*"},
+	}
+}
+
+// ThrottleConfigFromConfig builds a throttle configuration from service configuration.
+func ThrottleConfigFromConfig(cfg config.SettlementConfig) ThrottleConfig {
+	c := DefaultThrottleConfig()
+	if cfg.ThrottleSlowLatency > 0 {
+		c.SlowLatency = cfg.ThrottleSlowLatency
+	}
+	if cfg.ThrottlePauseLatency > 0 {
+		c.PauseLatency = cfg.ThrottlePauseLatency
+	}
+	if cfg.ThrottleSlowCongestion > 0 {
+		c.SlowCongestion = cfg.ThrottleSlowCongestion
+	}
+	if cfg.ThrottlePauseCongestion > 0 {
+		c.PauseCongestion = cfg.ThrottlePauseCongestion
+	}
+	if cfg.ThrottleSlowInterval > 0 {
+		c.SlowInterval = cfg.ThrottleSlowInterval
+	}
+	c.RerouteCorridors = cfg.RerouteCorridors
+	return c
+}
+
+// NetworkThrottle is the throttle state of one network as shown to operators.
+type NetworkThrottle struct {
+	Network          domain.BlockchainNetwork `json:"network"`
+	State            ThrottleState            `json:"state"`
+	Reason           string                   `json:"reason,omitempty"`
+	HealthStatus     string                   `json:"health_status,omitempty"`
+	LastLatencyMs    int64                    `json:"last_latency_ms"`
+	Congestion       float64                  `json:"congestion"`
+	Failures         int                      `json:"consecutive_failures"`
+	LastSubmittedAt  *time.Time               `json:"last_submitted_at,omitempty"`
+	NextSubmissionAt *time.Time               `json:"next_submission_at,omitempty"`
+}
+
+type networkSignals struct {
+	latency      time.Duration
+	latencyAt    time.Time
+	congestion   float64
+	congestionAt time.Time
+	failures     int
+	failureAt    time.Time
+	lastSubmit   time.Time
+
+	health   string
+	healthAt time.Time
+}
+
+// Throttle decides per network whether settlements may be submitted now,
+// based on the health monitor and on latency, congestion and errors observed
+// during recent submissions.
+type Throttle struct {
+	cfg    ThrottleConfig
+	health HealthMonitor
+
+	mu       sync.Mutex
+	networks map[domain.BlockchainNetwork]*networkSignals
+}
+
+func NewThrottle(cfg ThrottleConfig, health HealthMonitor) *Throttle {
+	return &Throttle{
+		cfg:      cfg,
+		health:   health,
+		networks: make(map[domain.BlockchainNetwork]*networkSignals),
+	}
+}
+
+// WithThrottle enables congestion-aware submission throttling.
+func (s *Service) WithThrottle(t *Throttle) *Service {
+	s.throttle = t
+	return s
+}
+
+func (t *Throttle) signals(network domain.BlockchainNetwork) *networkSignals {
+	sig, ok := t.networks[network]
+	if !ok {
+		sig = &networkSignals{}
+		t.networks[network] = sig
+	}
+	return sig
+}
+
+// ObserveSubmission records the outcome and latency of a submission attempt.
+func (t *Throttle) ObserveSubmission(network domain.BlockchainNetwork, latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	sig := t.signals(network)
+	sig.lastSubmit = now
+	sig.latency = latency
+	sig.latencyAt = now
+	if err != nil {
+		sig.failures++
+		sig.failureAt = now
+	} else {
+		sig.failures = 0
+	}
+}
+
+// ObserveCongestion records the congestion level reported by a fee quote.
+func (t *Throttle) ObserveCongestion(network domain.BlockchainNetwork, congestion float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sig := t.signals(network)
+	sig.congestion = congestion
+	sig.congestionAt = time.Now()
+}
+
+// refreshHealth reloads the monitor status once HealthTTL has passed. A network
+// unknown to the monitor is treated as healthy.
+func (t *Throttle) refreshHealth(ctx context.Context, network domain.BlockchainNetwork) {
+	if t.health == nil {
+		return
+	}
+	t.mu.Lock()
+	sig := t.signals(network)
+	stale := time.Since(sig.healthAt) >= t.cfg.HealthTTL
+	t.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	status := ""
+	if info, err := t.health.GetNetwork(ctx, string(network)); err == nil && info != nil {
+		status = strings.ToLower(info.Status)
+	}
+
+	t.mu.Lock()
+	sig.health = status
+	sig.healthAt = time.Now()
+	t.mu.Unlock()
+}
+
+// evaluate computes the state of a network. Callers must hold t.mu.
+func (t *Throttle) evaluate(network domain.BlockchainNetwork, now time.Time) NetworkThrottle {
+	sig := t.signals(network)
+	fresh := func(at time.Time) bool { return !at.IsZero() && now.Sub(at) < t.cfg.SignalTTL }
+
+	out := NetworkThrottle{
+		Network:      network,
+		State:        ThrottleNormal,
+		HealthStatus: sig.health,
+	}
+	if fresh(sig.latencyAt) {
+		out.LastLatencyMs = sig.latency.Milliseconds()
+	}
+	if fresh(sig.congestionAt) {
+		out.Congestion = sig.congestion
+	}
+	if fresh(sig.failureAt) {
+		out.Failures = sig.failures
+	}
+	if !sig.lastSubmit.IsZero() {
+		last := sig.lastSubmit
+		out.LastSubmittedAt = &last
+	}
+
+	latency := time.Duration(out.LastLatencyMs) * time.Millisecond
+	switch {
+	case sig.health == "down":
+		out.State, out.Reason = ThrottlePaused, "network marked down by health monitor"
+	case t.cfg.MaxFailures > 0 && out.Failures >= t.cfg.MaxFailures:
+		out.State, out.Reason = ThrottlePaused, fmt.Sprintf("%d consecutive submission failures", out.Failures)
+	case t.cfg.PauseLatency > 0 && latency >= t.cfg.PauseLatency:
+		out.State, out.Reason = ThrottlePaused, fmt.Sprintf("submission latency %s", latency)
+	case t.cfg.PauseCongestion > 0 && out.Congestion >= t.cfg.PauseCongestion:
+		out.State, out.Reason = ThrottlePaused, fmt.Sprintf("network congestion %.2f", out.Congestion)
+	case sig.health == "degraded":
+		out.State, out.Reason = ThrottleSlowed, "network marked degraded by health monitor"
+	case t.cfg.SlowLatency > 0 && latency >= t.cfg.SlowLatency:
+		out.State, out.Reason = ThrottleSlowed, fmt.Sprintf("submission latency %s", latency)
+	case t.cfg.SlowCongestion > 0 && out.Congestion >= t.cfg.SlowCongestion:
+		out.State, out.Reason = ThrottleSlowed, fmt.Sprintf("network congestion %.2f", out.Congestion)
+	}
+
+	if out.State == ThrottleSlowed && !sig.lastSubmit.IsZero() {
+		next := sig.lastSubmit.Add(t.cfg.SlowInterval)
+		if next.After(now) {
+			out.NextSubmissionAt = &next
+		}
+	}
+	return out
+}
+
+// Allow reports whether a settlement may be submitted on the network now.
+func (t *Throttle) Allow(ctx context.Context, network domain.BlockchainNetwork) (bool, NetworkThrottle) {
+	t.refreshHealth(ctx, network)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.evaluate(network, time.Now())
+	switch st.State {
+	case ThrottlePaused:
+		return false, st
+	case ThrottleSlowed:
+		return st.NextSubmissionAt == nil, st
+	default:
+		return true, st
+	}
+}
+
+// Status returns the current throttle state of the given networks.
+func (t *Throttle) Status(ctx context.Context, networks ...domain.BlockchainNetwork) []NetworkThrottle {
+	out := make([]NetworkThrottle, 0, len(networks))
+	for _, n := range networks {
+		t.refreshHealth(ctx, n)
+		t.mu.Lock()
+		out = append(out, t.evaluate(n, time.Now()))
+		t.mu.Unlock()
+	}
+	return out
+}
+
+// CanReroute reports whether settlements on the corridor may switch rails.
+func (t *Throttle) CanReroute(corridor string) bool {
+	for _, c := range t.cfg.RerouteCorridors {
+		if c == "*" || strings.EqualFold(c, corridor) {
+			return true
+		}
+	}
+	return false
+}
+
+func alternateNetwork(network domain.BlockchainNetwork) domain.BlockchainNetwork {
+	if network == domain.NetworkRipple {
+		return domain.NetworkStellar
+	}
+	return domain.NetworkRipple
+}
+
+// admit checks the throttle for a settlement. When its network is paused and
+// the corridor is eligible, the settlement is moved to the alternate rail if
+// that rail is accepting submissions. It returns a defer reason, or "" to proceed.
+func (s *Service) admit(ctx context.Context, set *domain.Settlement) string {
+	if s.throttle == nil {
+		return ""
+	}
+	ok, st := s.throttle.Allow(ctx, set.Network)
+	if ok {
+		return ""
+	}
+
+	corridor, _ := set.Metadata["corridor"].(string)
+	if st.State == ThrottlePaused && s.throttle.CanReroute(corridor) {
+		alt := alternateNetwork(set.Network)
+		if altOK, _ := s.throttle.Allow(ctx, alt); altOK {
+			if set.Metadata == nil {
+				set.Metadata = make(domain.Metadata)
+			}
+			set.Metadata["rerouted_from"] = string(set.Network)
+			set.Metadata["reroute_reason"] = st.Reason
+			s.logger.Warn("Settlement rerouted to alternate rail", map[string]interface{}{
+				"settlement_id": set.ID,
+				"from":          set.Network,
+				"to":            alt,
+				"reason":        st.Reason,
+			})
+			set.Network = alt
+			return ""
+		}
+	}
+	return fmt.Sprintf("network %s %s: %s", set.Network, st.State, st.Reason)
+}
+
+// ThrottleStatus returns the throttle state of each settlement network.
+func (s *Service) ThrottleStatus(ctx context.Context) []NetworkThrottle {
+	if s.throttle == nil {
+		return []NetworkThrottle{
+			{Network: domain.NetworkStellar, State: ThrottleNormal},
+			{Network: domain.NetworkRipple, State: ThrottleNormal},
+		}
+	}
+	return s.throttle.Status(ctx, domain.NetworkStellar, domain.NetworkRipple)
+}
@@ -0,0 +1,83 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockHealthMonitor struct {
+	mock.Mock
+}
+
+func (m *MockHealthMonitor) GetNetwork(ctx context.Context, id string) (*domain.BlockchainNetworkInfo, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BlockchainNetworkInfo), args.Error(1)
+}
+
+func TestThrottle_HealthMonitorPausesNetwork(t *testing.T) {
+	health := new(MockHealthMonitor)
+	health.On("GetNetwork", mock.Anything, "stellar").Return(&domain.BlockchainNetworkInfo{Status: "down"}, nil)
+	health.On("GetNetwork", mock.Anything, "ripple").Return(nil, errors.New("not found"))
+
+	th := NewThrottle(DefaultThrottleConfig(), health)
+
+	ok, st := th.Allow(context.Background(), domain.NetworkStellar)
+	assert.False(t, ok)
+	assert.Equal(t, ThrottlePaused, st.State)
+
+	ok, st = th.Allow(context.Background(), domain.NetworkRipple)
+	assert.True(t, ok)
+	assert.Equal(t, ThrottleNormal, st.State)
+}
+
+func TestThrottle_SlowsOnLatencyAndPausesOnFailures(t *testing.T) {
+	th := NewThrottle(DefaultThrottleConfig(), nil)
+
+	th.ObserveSubmission(domain.NetworkRipple, 10*time.Second, nil)
+	ok, st := th.Allow(context.Background(), domain.NetworkRipple)
+	assert.False(t, ok, "slowed network must wait for the slow interval")
+	assert.Equal(t, ThrottleSlowed, st.State)
+	assert.NotNil(t, st.NextSubmissionAt)
+
+	for i := 0; i < 3; i++ {
+		th.ObserveSubmission(domain.NetworkRipple, time.Millisecond, errors.New("rpc timeout"))
+	}
+	_, st = th.Allow(context.Background(), domain.NetworkRipple)
+	assert.Equal(t, ThrottlePaused, st.State)
+
+	th.ObserveSubmission(domain.NetworkRipple, time.Millisecond, nil)
+	ok, st = th.Allow(context.Background(), domain.NetworkRipple)
+	assert.True(t, ok)
+	assert.Equal(t, ThrottleNormal, st.State)
+}
+
+func TestAdmit_ReroutesEligibleCorridor(t *testing.T) {
+	cfg := DefaultThrottleConfig()
+	cfg.RerouteCorridors = []string{"MWK-CNY"}
+	th := NewThrottle(cfg, nil)
+	th.ObserveCongestion(domain.NetworkStellar, 0.99)
+
+	mockLog := new(MockLogger)
+	mockLog.On("Warn", mock.Anything, mock.Anything).Return()
+	s := &Service{logger: mockLog, throttle: th}
+
+	eligible := &domain.Settlement{ID: uuid.New(), Network: domain.NetworkStellar, Metadata: domain.Metadata{"corridor": "MWK-CNY"}}
+	assert.Empty(t, s.admit(context.Background(), eligible))
+	assert.Equal(t, domain.NetworkRipple, eligible.Network)
+	assert.Equal(t, "stellar", eligible.Metadata["rerouted_from"])
+
+	other := &domain.Settlement{ID: uuid.New(), Network: domain.NetworkStellar, Metadata: domain.Metadata{"corridor": "ZMW-CNY"}}
+	assert.Contains(t, s.admit(context.Background(), other), "paused")
+	assert.Equal(t, domain.NetworkStellar, other.Network)
+}
@@ -0,0 +1,390 @@
+package smoke
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Checks are the golden paths, in the order "all" runs them.
+var Checks = []Check{
+	{Name: "send-money", Description: "fund a wallet, pay another customer, verify both balances", Run: checkSendMoney},
+	{Name: "escrow", Description: "reserve funds in escrow, release to the receiver, refund a second escrow", Run: checkEscrow},
+	{Name: "compliance", Description: "submit KYC documents and approve them as an admin", Run: checkCompliance},
+	{Name: "settlement", Description: "pay, trigger a settlement run and verify the payment is batched", Run: checkSettlement},
+	{Name: "admin", Description: "admin endpoints answer for admins and refuse customers", Run: checkAdmin},
+}
+
+// customer registers a KYC-verified customer and logs in.
+func customer(ctx context.Context, env *Env, level int) (*Session, error) {
+	s, err := env.Client.Register(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("register: %w", err)
+	}
+	if err := env.Fixtures.VerifyKYC(ctx, s.UserID, level); err != nil {
+		return nil, fmt.Errorf("verify kyc: %w", err)
+	}
+	if err := env.Client.Login(ctx, s); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	env.Step("customer %s ready", s.Email)
+	return s, nil
+}
+
+// admin logs in with the configured admin, or promotes a fresh account.
+func admin(ctx context.Context, env *Env) (*Session, error) {
+	if env.AdminEmail != "" {
+		s := &Session{Email: env.AdminEmail, Password: env.AdminPassword}
+		if err := env.Client.Login(ctx, s); err != nil {
+			return nil, fmt.Errorf("admin login: %w", err)
+		}
+		env.Step("admin %s logged in", s.Email)
+		return s, nil
+	}
+	s, err := env.Client.Register(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("register admin: %w", err)
+	}
+	if err := env.Fixtures.PromoteAdmin(ctx, s.UserID); err != nil {
+		return nil, fmt.Errorf("promote admin: %w", err)
+	}
+	if err := env.Client.Login(ctx, s); err != nil {
+		return nil, fmt.Errorf("admin login: %w", err)
+	}
+	env.Step("admin %s ready", s.Email)
+	return s, nil
+}
+
+// wallet opens an MWK wallet and deposits amount into it.
+func wallet(ctx context.Context, env *Env, s *Session, amount int64) (*domain.Wallet, error) {
+	var w domain.Wallet
+	if err := env.Client.Do(ctx, s, http.MethodPost, "/api/v1/wallets", map[string]string{"currency": string(domain.MWK)}, &w); err != nil {
+		return nil, fmt.Errorf("create wallet: %w", err)
+	}
+	if amount > 0 {
+		err := env.Client.Do(ctx, s, http.MethodPost, "/api/v1/wallets/"+w.ID.String()+"/deposit", map[string]interface{}{
+			"amount":    decimal.NewFromInt(amount),
+			"source_id": "smoke",
+			"currency":  domain.MWK,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("deposit: %w", err)
+		}
+	}
+	env.Step("wallet %s opened with %d MWK", w.ID, amount)
+	return &w, nil
+}
+
+// pay sends amount MWK from sender to receiver.
+func pay(ctx context.Context, env *Env, sender, receiver *Session, amount int64) (*domain.Transaction, error) {
+	var resp payment.PaymentResponse
+	err := env.Client.Do(ctx, sender, http.MethodPost, "/api/v1/payments", map[string]interface{}{
+		"receiver_id": receiver.UserID,
+		"amount":      decimal.NewFromInt(amount),
+		"currency":    domain.MWK,
+		"description": "smoke check",
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("payment: %w", err)
+	}
+	if resp.Transaction == nil {
+		return nil, fmt.Errorf("payment: no transaction in reply")
+	}
+	if resp.Transaction.Status == domain.TransactionStatusFailed {
+		return nil, fmt.Errorf("payment %s failed: %s", resp.Transaction.ID, resp.Transaction.StatusReason)
+	}
+	env.Step("payment %s %s", resp.Transaction.ID, resp.Transaction.Status)
+	return resp.Transaction, nil
+}
+
+// expectBalance compares a wallet's available balance with want.
+func expectBalance(ctx context.Context, env *Env, walletID uuid.UUID, want decimal.Decimal, who string) error {
+	got, _, err := env.Fixtures.Balances(ctx, walletID)
+	if err != nil {
+		return fmt.Errorf("%s balance: %w", who, err)
+	}
+	if !got.Equal(want) {
+		return fmt.Errorf("%s balance is %s, want %s", who, got, want)
+	}
+	return nil
+}
+
+func checkSendMoney(ctx context.Context, env *Env) error {
+	sender, err := customer(ctx, env, 2)
+	if err != nil {
+		return err
+	}
+	receiver, err := customer(ctx, env, 1)
+	if err != nil {
+		return err
+	}
+	senderWallet, err := wallet(ctx, env, sender, 100000)
+	if err != nil {
+		return err
+	}
+	receiverWallet, err := wallet(ctx, env, receiver, 0)
+	if err != nil {
+		return err
+	}
+
+	tx, err := pay(ctx, env, sender, receiver, 25000)
+	if err != nil {
+		return err
+	}
+	debited := decimal.NewFromInt(25000).Add(tx.FeeAmount)
+	if err := expectBalance(ctx, env, senderWallet.ID, decimal.NewFromInt(100000).Sub(debited), "sender"); err != nil {
+		return err
+	}
+	if err := expectBalance(ctx, env, receiverWallet.ID, tx.NetAmount, "receiver"); err != nil {
+		return err
+	}
+	env.Step("balances settled")
+
+	// More than the balance is refused and moves nothing.
+	err = env.Client.Expect(ctx, http.StatusBadRequest, sender, http.MethodPost, "/api/v1/payments", map[string]interface{}{
+		"receiver_id": receiver.UserID,
+		"amount":      decimal.NewFromInt(1000000),
+		"currency":    domain.MWK,
+	})
+	if err != nil {
+		return err
+	}
+	if err := expectBalance(ctx, env, senderWallet.ID, decimal.NewFromInt(100000).Sub(debited), "sender after refused payment"); err != nil {
+		return err
+	}
+	env.Step("overdraft refused")
+	return nil
+}
+
+func checkEscrow(ctx context.Context, env *Env) error {
+	svc, err := env.Fixtures.PaymentService(logger.NewNop())
+	if err != nil {
+		return err
+	}
+	buyer, err := customer(ctx, env, 2)
+	if err != nil {
+		return err
+	}
+	seller, err := customer(ctx, env, 1)
+	if err != nil {
+		return err
+	}
+	buyerWallet, err := wallet(ctx, env, buyer, 50000)
+	if err != nil {
+		return err
+	}
+	sellerWallet, err := wallet(ctx, env, seller, 0)
+	if err != nil {
+		return err
+	}
+
+	escrow := func(amount int64) (uuid.UUID, error) {
+		resp, err := svc.CreateEscrow(ctx, &payment.EscrowRequest{
+			SenderID:   buyer.UserID,
+			ReceiverID: seller.UserID,
+			Amount:     decimal.NewFromInt(amount),
+			Currency:   domain.MWK,
+			Condition:  "smoke check",
+			Expiry:     time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("create escrow: %w", err)
+		}
+		env.Step("escrow %s reserved", resp.Transaction.ID)
+		return resp.Transaction.ID, nil
+	}
+
+	released, err := escrow(20000)
+	if err != nil {
+		return err
+	}
+	if err := expectBalance(ctx, env, buyerWallet.ID, decimal.NewFromInt(30000), "buyer"); err != nil {
+		return err
+	}
+	if err := svc.ReleaseEscrow(ctx, released, buyer.UserID); err != nil {
+		return fmt.Errorf("release escrow: %w", err)
+	}
+	if err := expectStatus(ctx, env, released, domain.TransactionStatusCompleted); err != nil {
+		return err
+	}
+	if err := expectBalance(ctx, env, sellerWallet.ID, decimal.NewFromInt(20000), "seller"); err != nil {
+		return err
+	}
+	env.Step("escrow released to seller")
+
+	refunded, err := escrow(10000)
+	if err != nil {
+		return err
+	}
+	if err := svc.RefundEscrow(ctx, refunded, buyer.UserID); err != nil {
+		return fmt.Errorf("refund escrow: %w", err)
+	}
+	if err := expectStatus(ctx, env, refunded, domain.TransactionStatusCancelled); err != nil {
+		return err
+	}
+	if err := expectBalance(ctx, env, buyerWallet.ID, decimal.NewFromInt(30000), "buyer after refund"); err != nil {
+		return err
+	}
+	env.Step("escrow refunded to buyer")
+	return nil
+}
+
+func expectStatus(ctx context.Context, env *Env, txID uuid.UUID, want domain.TransactionStatus) error {
+	got, err := env.Fixtures.TransactionStatus(ctx, txID)
+	if err != nil {
+		return fmt.Errorf("transaction %s: %w", txID, err)
+	}
+	if got != want {
+		return fmt.Errorf("transaction %s is %s, want %s", txID, got, want)
+	}
+	return nil
+}
+
+// kycDocument is a 1x1 PNG, enough for the upload to be stored.
+var kycDocument = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4,
+	0x89, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x00, 0x01, 0x00, 0x00,
+	0x05, 0x00, 0x01, 0x0d, 0x0a, 0x2d, 0xb4, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44, 0xae,
+	0x42, 0x60, 0x82,
+}
+
+func checkCompliance(ctx context.Context, env *Env) error {
+	applicant, err := env.Client.Register(ctx)
+	if err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+	if err := env.Client.Login(ctx, applicant); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	env.Step("applicant %s registered", applicant.Email)
+	reviewer, err := admin(ctx, env)
+	if err != nil {
+		return err
+	}
+
+	err = env.Client.Upload(ctx, applicant, "/api/v1/compliance/kyc/submit", map[string]string{
+		"document_type":   "national_id",
+		"document_number": "SMK" + applicant.UserID.String()[:8],
+		"issuing_country": "MW",
+	}, "documents", "smoke.png", kycDocument, nil)
+	if err != nil {
+		return fmt.Errorf("submit kyc: %w", err)
+	}
+	env.Step("kyc submitted")
+
+	// Applicants cannot approve themselves.
+	path := "/api/v1/admin/compliance/kyc/" + applicant.UserID.String()
+	approve := map[string]string{"status": string(domain.KYCStatusVerified), "reason": "smoke check"}
+	if err := env.Client.Expect(ctx, http.StatusForbidden, applicant, http.MethodPatch, path, approve); err != nil {
+		return err
+	}
+	if err := env.Client.Do(ctx, reviewer, http.MethodPatch, path, approve, nil); err != nil {
+		return fmt.Errorf("approve kyc: %w", err)
+	}
+	status, err := env.Fixtures.KYCStatus(ctx, applicant.UserID)
+	if err != nil {
+		return fmt.Errorf("kyc status: %w", err)
+	}
+	if status != domain.KYCStatusVerified {
+		return fmt.Errorf("kyc status is %s after approval", status)
+	}
+	if err := env.Client.Do(ctx, applicant, http.MethodGet, "/api/v1/compliance/kyc/status", nil, nil); err != nil {
+		return fmt.Errorf("kyc status endpoint: %w", err)
+	}
+	env.Step("kyc approved")
+	return nil
+}
+
+func checkSettlement(ctx context.Context, env *Env) error {
+	sender, err := customer(ctx, env, 2)
+	if err != nil {
+		return err
+	}
+	receiver, err := customer(ctx, env, 1)
+	if err != nil {
+		return err
+	}
+	operator, err := admin(ctx, env)
+	if err != nil {
+		return err
+	}
+	if _, err := wallet(ctx, env, sender, 60000); err != nil {
+		return err
+	}
+	if _, err := wallet(ctx, env, receiver, 0); err != nil {
+		return err
+	}
+	tx, err := pay(ctx, env, sender, receiver, 15000)
+	if err != nil {
+		return err
+	}
+
+	if err := env.Client.Expect(ctx, http.StatusForbidden, sender, http.MethodPost, "/api/v1/settlements/process", nil); err != nil {
+		return err
+	}
+	if err := env.Client.Do(ctx, operator, http.MethodPost, "/api/v1/settlements/process", nil, nil); err != nil {
+		return fmt.Errorf("trigger settlement: %w", err)
+	}
+	env.Step("settlement run triggered")
+
+	// The background settlement worker may batch it first; either is fine.
+	for {
+		id, status, err := env.Fixtures.SettlementOf(ctx, tx.ID)
+		if err != nil {
+			return fmt.Errorf("settlement of %s: %w", tx.ID, err)
+		}
+		if id != uuid.Nil {
+			if status == domain.SettlementStatusFailed {
+				return fmt.Errorf("settlement %s failed", id)
+			}
+			env.Step("payment batched in settlement %s (%s)", id, status)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("payment %s was not batched for settlement: %w", tx.ID, ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func checkAdmin(ctx context.Context, env *Env) error {
+	operator, err := admin(ctx, env)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{
+		"/api/v1/admin/dashboard",
+		"/api/v1/admin/users?limit=5",
+		"/api/v1/admin/transactions?limit=5",
+		"/api/v1/admin/disputes?limit=5",
+		"/api/v1/admin/audit-logs?limit=5",
+		"/api/v1/admin/system/status",
+	} {
+		if err := env.Client.Do(ctx, operator, http.MethodGet, path, nil, nil); err != nil {
+			return err
+		}
+		env.Step("GET %s", path)
+	}
+
+	outsider, err := customer(ctx, env, 1)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{"/api/v1/admin/users", "/api/v1/admin/disputes"} {
+		if err := env.Client.Expect(ctx, http.StatusForbidden, outsider, http.MethodGet, path, nil); err != nil {
+			return err
+		}
+	}
+	env.Step("customers refused")
+	return nil
+}
@@ -0,0 +1,189 @@
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// Password of the accounts smoke runs register.
+const accountPassword = "Smoke-Check-Pass-2026!"
+
+// Client calls the API through the gateway.
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for baseURL.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTP: &http.Client{Timeout: timeout}}
+}
+
+// Session is a logged-in account.
+type Session struct {
+	UserID   uuid.UUID
+	Email    string
+	Password string
+	Token    string
+}
+
+// StatusError is a reply with an unexpected status code.
+type StatusError struct {
+	Method, Path string
+	Code         int
+	Body         string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: status %d: %s", e.Method, e.Path, e.Code, e.Body)
+}
+
+// Do sends a JSON request, with the session's token when s is non-nil, and
+// decodes a 2xx reply into out. Other replies are returned as *StatusError.
+// Writes carry a fresh Idempotency-Key.
+func (c *Client) Do(ctx context.Context, s *Session, method, path string, body, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.send(req, s, out)
+}
+
+// Upload posts a multipart form with one file.
+func (c *Client) Upload(ctx context.Context, s *Session, path string, fields map[string]string, fileField, fileName string, content []byte, out interface{}) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	fw, err := mw.CreateFormFile(fileField, fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(content); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return c.send(req, s, out)
+}
+
+func (c *Client) send(req *http.Request, s *Session, out interface{}) error {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		req.Header.Set("Idempotency-Key", uuid.NewString())
+	}
+	if s != nil && s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{Method: req.Method, Path: req.URL.Path, Code: resp.StatusCode, Body: strings.TrimSpace(string(raw))}
+	}
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("%s %s: decode reply: %w", req.Method, req.URL.Path, err)
+		}
+	}
+	return nil
+}
+
+// Expect calls Do and requires the reply to fail with code.
+func (c *Client) Expect(ctx context.Context, code int, s *Session, method, path string, body interface{}) error {
+	err := c.Do(ctx, s, method, path, body, nil)
+	if se, ok := err.(*StatusError); ok && se.Code == code {
+		return nil
+	}
+	if err == nil {
+		return fmt.Errorf("%s %s: expected status %d, got success", method, path, code)
+	}
+	return fmt.Errorf("%s %s: expected status %d: %w", method, path, code, err)
+}
+
+// Health checks the gateway's health endpoint.
+func (c *Client) Health(ctx context.Context) error {
+	return c.Do(ctx, nil, http.MethodGet, "/health", nil, nil)
+}
+
+// Register creates a Malawian individual account named smoke-<random> so
+// smoke data is easy to find and purge.
+func (c *Client) Register(ctx context.Context) (*Session, error) {
+	s := &Session{
+		Email:    fmt.Sprintf("smoke-%s@example.com", uuid.NewString()[:12]),
+		Password: accountPassword,
+	}
+	var resp struct {
+		User domain.User `json:"user"`
+	}
+	err := c.Do(ctx, nil, http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"email":        s.Email,
+		"phone":        fmt.Sprintf("+26599%07d", rand.Intn(10000000)),
+		"password":     s.Password,
+		"first_name":   "Smoke",
+		"last_name":    "Check",
+		"user_type":    string(domain.UserTypeIndividual),
+		"country_code": "MW",
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	s.UserID = resp.User.ID
+	return s, nil
+}
+
+// Login (re)issues the session's token, picking up role or KYC changes.
+func (c *Client) Login(ctx context.Context, s *Session) error {
+	var resp struct {
+		AccessToken string      `json:"access_token"`
+		User        domain.User `json:"user"`
+	}
+	err := c.Do(ctx, nil, http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"email":    s.Email,
+		"password": s.Password,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	if resp.AccessToken == "" {
+		return fmt.Errorf("login %s: no access token", s.Email)
+	}
+	s.Token = resp.AccessToken
+	s.UserID = resp.User.ID
+	return nil
+}
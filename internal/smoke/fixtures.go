@@ -0,0 +1,114 @@
+package smoke
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"kyd/internal/domain"
+	"kyd/internal/ledger"
+	"kyd/internal/payment"
+	"kyd/internal/repository/postgres"
+	"kyd/internal/security"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+// Fixtures seeds accounts and reads back state directly in the database.
+type Fixtures struct {
+	db *sqlx.DB
+}
+
+// NewFixtures wraps an open connection to the target's database.
+func NewFixtures(db *sqlx.DB) *Fixtures {
+	return &Fixtures{db: db}
+}
+
+// VerifyKYC marks the user KYC verified at level and backdates the account
+// past the new-account risk rules, so payments are not held for review.
+func (f *Fixtures) VerifyKYC(ctx context.Context, userID uuid.UUID, level int) error {
+	_, err := f.db.ExecContext(ctx, `
+		UPDATE customer_schema.users
+		SET kyc_status = 'verified', kyc_level = $2, email_verified = TRUE,
+			created_at = NOW() - INTERVAL '90 days'
+		WHERE id = $1`, userID, level)
+	return err
+}
+
+// PromoteAdmin turns the user into a superadmin holding the admin role.
+func (f *Fixtures) PromoteAdmin(ctx context.Context, userID uuid.UUID) error {
+	_, err := f.db.ExecContext(ctx, `
+		UPDATE customer_schema.users
+		SET user_type = 'admin', admin_role = 'superadmin', email_verified = TRUE
+		WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	_, err = f.db.ExecContext(ctx, `
+		INSERT INTO admin_schema.user_roles (user_id, role) VALUES ($1, 'admin')
+		ON CONFLICT DO NOTHING`, userID)
+	return err
+}
+
+// KYCStatus reads the user's KYC status.
+func (f *Fixtures) KYCStatus(ctx context.Context, userID uuid.UUID) (domain.KYCStatus, error) {
+	var status domain.KYCStatus
+	err := f.db.QueryRowContext(ctx, `SELECT kyc_status FROM customer_schema.users WHERE id = $1`, userID).Scan(&status)
+	return status, err
+}
+
+// Balances reads a wallet's available and reserved balances.
+func (f *Fixtures) Balances(ctx context.Context, walletID uuid.UUID) (available, reserved decimal.Decimal, err error) {
+	err = f.db.QueryRowContext(ctx, `
+		SELECT available_balance, reserved_balance FROM customer_schema.wallets WHERE id = $1`,
+		walletID).Scan(&available, &reserved)
+	return available, reserved, err
+}
+
+// TransactionStatus reads a transaction's status.
+func (f *Fixtures) TransactionStatus(ctx context.Context, txID uuid.UUID) (domain.TransactionStatus, error) {
+	var status domain.TransactionStatus
+	err := f.db.QueryRowContext(ctx, `SELECT status FROM customer_schema.transactions WHERE id = $1`, txID).Scan(&status)
+	return status, err
+}
+
+// SettlementOf returns the settlement batch a transaction was assigned to
+// and the batch's status, or uuid.Nil while it is unbatched.
+func (f *Fixtures) SettlementOf(ctx context.Context, txID uuid.UUID) (uuid.UUID, domain.SettlementStatus, error) {
+	var id uuid.NullUUID
+	var status sql.NullString
+	err := f.db.QueryRowContext(ctx, `
+		SELECT t.settlement_id, s.status
+		FROM customer_schema.transactions t
+		LEFT JOIN customer_schema.settlements s ON s.id = t.settlement_id
+		WHERE t.id = $1`, txID).Scan(&id, &status)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return id.UUID, domain.SettlementStatus(status.String), nil
+}
+
+// PaymentService builds a payment service on the target's database for flows
+// driven directly on the service (escrow). It needs the deployment's ENCRYPTION_KEY
+// and HMAC_KEY in the environment.
+func (f *Fixtures) PaymentService(log logger.Logger) (*payment.Service, error) {
+	crypto, err := security.NewCryptoService()
+	if err != nil {
+		return nil, fmt.Errorf("crypto service: %w", err)
+	}
+	txRepo := postgres.NewTransactionRepository(f.db)
+	return payment.NewService(
+		txRepo,
+		postgres.NewWalletRepository(f.db),
+		nil, nil,
+		postgres.NewUserRepository(f.db, crypto),
+		nil,
+		postgres.NewAuditRepository(f.db, crypto),
+		postgres.NewSecurityRepository(f.db),
+		log,
+		nil,
+	).WithEscrows(txRepo, ledger.NewService(f.db, postgres.NewLedgerRepository(f.db))), nil
+}
@@ -0,0 +1,163 @@
+// Package smoke runs golden-path checks against a deployed stack for
+// deployment gating. Checks drive the public API through the gateway and
+// use the database only to seed accounts (KYC, admin role) and to read back
+// state the API does not expose. The report is JSON so pipelines can gate on
+// it, and cmd/smoke maps it to an exit code.
+package smoke
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Exit codes of cmd/smoke.
+const (
+	ExitPassed = 0 // every check passed
+	ExitFailed = 1 // at least one check failed
+	ExitUsage  = 2 // the run could not start: bad arguments or configuration
+)
+
+// Status of a check.
+type Status string
+
+const (
+	StatusPassed Status = "passed"
+	StatusFailed Status = "failed"
+)
+
+// Check is one golden-path scenario.
+type Check struct {
+	Name        string
+	Description string
+	Run         func(ctx context.Context, env *Env) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name       string   `json:"name"`
+	Status     Status   `json:"status"`
+	DurationMS int64    `json:"duration_ms"`
+	Steps      []string `json:"steps"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Target     string    `json:"target"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Passed     bool      `json:"passed"`
+	Checks     []Result  `json:"checks"`
+}
+
+// ExitCode is the process exit code for the report.
+func (r *Report) ExitCode() int {
+	if r.Passed {
+		return ExitPassed
+	}
+	return ExitFailed
+}
+
+// Env is what checks run against. Checks record progress with Step so a
+// failure report shows how far the scenario got.
+type Env struct {
+	Client   *Client
+	Fixtures *Fixtures
+	// Admin logs in with these instead of promoting a fresh account.
+	AdminEmail    string
+	AdminPassword string
+
+	mu    sync.Mutex
+	steps []string
+}
+
+// Step records that a stage of the current check completed.
+func (e *Env) Step(format string, args ...interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.steps = append(e.steps, fmt.Sprintf(format, args...))
+}
+
+func (e *Env) takeSteps() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	steps := e.steps
+	e.steps = nil
+	if steps == nil {
+		steps = []string{}
+	}
+	return steps
+}
+
+// Run runs checks in order, each under its own timeout, and keeps going
+// after a failure so the report covers every check. When the gateway is not
+// healthy every check fails without running.
+func Run(ctx context.Context, env *Env, checks []Check, timeout time.Duration) *Report {
+	report := &Report{Target: env.Client.BaseURL, StartedAt: time.Now().UTC(), Passed: true}
+	hctx, cancel := context.WithTimeout(ctx, timeout)
+	healthErr := env.Client.Health(hctx)
+	cancel()
+	for _, c := range checks {
+		var res Result
+		if healthErr != nil {
+			res = Result{Name: c.Name, Status: StatusFailed, Steps: []string{}, Error: "gateway unhealthy: " + healthErr.Error()}
+		} else {
+			res = runOne(ctx, env, c, timeout)
+		}
+		if res.Status != StatusPassed {
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, res)
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+	return report
+}
+
+func runOne(ctx context.Context, env *Env, c Check, timeout time.Duration) (res Result) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	started := time.Now()
+	res = Result{Name: c.Name, Status: StatusPassed}
+	defer func() {
+		if p := recover(); p != nil {
+			res.Status = StatusFailed
+			res.Error = fmt.Sprintf("panic: %v", p)
+		}
+		res.Steps = env.takeSteps()
+		res.DurationMS = time.Since(started).Milliseconds()
+	}()
+	if err := c.Run(ctx, env); err != nil {
+		res.Status = StatusFailed
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Select returns the named checks from all in the order given, or every
+// check when names is empty or "all".
+func Select(all []Check, names []string) ([]Check, error) {
+	if len(names) == 0 || (len(names) == 1 && names[0] == "all") {
+		return all, nil
+	}
+	byName := make(map[string]Check, len(all))
+	for _, c := range all {
+		byName[c.Name] = c
+	}
+	var out []Check
+	for _, n := range names {
+		c, ok := byName[n]
+		if !ok {
+			known := make([]string, 0, len(byName))
+			for k := range byName {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown check %q (known: %v)", n, known)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
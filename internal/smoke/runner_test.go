@@ -0,0 +1,129 @@
+package smoke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gateway(t *testing.T, healthy bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/echo":
+			json.NewEncoder(w).Encode(map[string]string{
+				"idempotency_key": r.Header.Get("Idempotency-Key"),
+				"authorization":   r.Header.Get("Authorization"),
+			})
+		default:
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRun_ReportsEveryCheck(t *testing.T) {
+	env := &Env{Client: NewClient(gateway(t, true).URL, time.Second)}
+	checks := []Check{
+		{Name: "ok", Run: func(ctx context.Context, env *Env) error {
+			env.Step("did %d things", 2)
+			return nil
+		}},
+		{Name: "broken", Run: func(ctx context.Context, env *Env) error {
+			env.Step("started")
+			return errors.New("balance is 0, want 10")
+		}},
+		{Name: "panics", Run: func(ctx context.Context, env *Env) error {
+			panic("nil wallet")
+		}},
+	}
+
+	report := Run(context.Background(), env, checks, time.Second)
+
+	require.Len(t, report.Checks, 3)
+	assert.False(t, report.Passed)
+	assert.Equal(t, ExitFailed, report.ExitCode())
+	assert.Equal(t, StatusPassed, report.Checks[0].Status)
+	assert.Equal(t, []string{"did 2 things"}, report.Checks[0].Steps)
+	assert.Equal(t, StatusFailed, report.Checks[1].Status)
+	assert.Equal(t, "balance is 0, want 10", report.Checks[1].Error)
+	assert.Equal(t, []string{"started"}, report.Checks[1].Steps, "steps do not leak between checks")
+	assert.Equal(t, "panic: nil wallet", report.Checks[2].Error)
+}
+
+func TestRun_AllPassed(t *testing.T) {
+	env := &Env{Client: NewClient(gateway(t, true).URL, time.Second)}
+	report := Run(context.Background(), env, []Check{{Name: "ok", Run: func(context.Context, *Env) error { return nil }}}, time.Second)
+
+	assert.True(t, report.Passed)
+	assert.Equal(t, ExitPassed, report.ExitCode())
+}
+
+func TestRun_UnhealthyGatewayFailsWithoutRunning(t *testing.T) {
+	env := &Env{Client: NewClient(gateway(t, false).URL, time.Second)}
+	ran := false
+	report := Run(context.Background(), env, []Check{{Name: "send-money", Run: func(context.Context, *Env) error {
+		ran = true
+		return nil
+	}}}, time.Second)
+
+	assert.False(t, ran)
+	assert.False(t, report.Passed)
+	assert.Contains(t, report.Checks[0].Error, "gateway unhealthy")
+}
+
+func TestSelect(t *testing.T) {
+	all, err := Select(Checks, nil)
+	require.NoError(t, err)
+	assert.Len(t, all, len(Checks))
+
+	all, err = Select(Checks, []string{"all"})
+	require.NoError(t, err)
+	assert.Len(t, all, len(Checks))
+
+	some, err := Select(Checks, []string{"settlement", "send-money"})
+	require.NoError(t, err)
+	require.Len(t, some, 2)
+	assert.Equal(t, "settlement", some[0].Name)
+	assert.Equal(t, "send-money", some[1].Name)
+
+	_, err = Select(Checks, []string{"verify_escrow"})
+	assert.Error(t, err)
+}
+
+func TestClient_HeadersAndStatusErrors(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(gateway(t, true).URL+"/", time.Second)
+	s := &Session{Token: "tok"}
+
+	var got map[string]string
+	require.NoError(t, c.Do(ctx, s, http.MethodPost, "/echo", map[string]int{"n": 1}, &got))
+	assert.NotEmpty(t, got["idempotency_key"])
+	assert.Equal(t, "Bearer tok", got["authorization"])
+
+	require.NoError(t, c.Do(ctx, nil, http.MethodGet, "/echo", nil, &got))
+	assert.Empty(t, got["idempotency_key"])
+	assert.Empty(t, got["authorization"])
+
+	err := c.Do(ctx, s, http.MethodGet, "/api/v1/admin/users", nil, nil)
+	var se *StatusError
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, http.StatusForbidden, se.Code)
+
+	assert.NoError(t, c.Expect(ctx, http.StatusForbidden, s, http.MethodGet, "/api/v1/admin/users", nil))
+	assert.Error(t, c.Expect(ctx, http.StatusForbidden, s, http.MethodGet, "/echo", nil))
+	assert.Error(t, c.Expect(ctx, http.StatusBadRequest, s, http.MethodGet, "/api/v1/admin/users", nil))
+}
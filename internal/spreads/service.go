@@ -0,0 +1,313 @@
+// Package spreads manages the FX spreads taken on currency pairs and
+// reports what they earn.
+//
+// Spreads are few and change rarely, so each instance keeps them in memory
+// and refreshes them every cache TTL; pricing a conversion then costs no
+// query. Revenue is worked out from the mid rate recorded on each payment,
+// so it matches what the ledger booked whatever the spread was then.
+package spreads
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrSpreadNotFound = errors.New("fx spread not found")
+	ErrInvalidSpread  = errors.New("invalid fx spread")
+	ErrInvalidReport  = errors.New("invalid fx revenue report")
+)
+
+const (
+	defaultCacheTTL = time.Minute
+	// maxPercent caps spreads set as a percentage of the mid rate.
+	maxPercent = 10
+	// maxReportDays bounds the window of one revenue report.
+	maxReportDays      = 366
+	defaultReportDays  = 30
+	revenueRoundPlaces = 2
+)
+
+// Repository persists spreads and reads the payments they earned on.
+type Repository interface {
+	// Save creates the pair's spread or replaces it.
+	Save(ctx context.Context, s *domain.FXSpread) error
+	Delete(ctx context.Context, from, to domain.Currency) (bool, error)
+	// Find returns the pair's spread, or nil.
+	Find(ctx context.Context, from, to domain.Currency) (*domain.FXSpread, error)
+	List(ctx context.Context) ([]domain.FXSpread, error)
+	// Revenue returns what completed payments matching f earned, per UTC
+	// day and corridor, oldest first. Payments without a recorded mid rate
+	// are left out.
+	Revenue(ctx context.Context, f domain.FXRevenueFilter) ([]domain.FXRevenue, error)
+}
+
+type Service struct {
+	repo   Repository
+	logger logger.Logger
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]domain.FXSpread
+	loadedAt time.Time
+}
+
+func NewService(repo Repository, log logger.Logger) *Service {
+	return &Service{repo: repo, logger: log, ttl: defaultCacheTTL, now: time.Now}
+}
+
+// WithCacheTTL sets how long spreads are served from memory. Changes made
+// on another instance take up to this long to apply here.
+func (s *Service) WithCacheTTL(d time.Duration) *Service {
+	if d > 0 {
+		s.ttl = d
+	}
+	return s
+}
+
+// SpreadRequest sets a pair's spread. PipSize only applies to spreads in
+// pips, and defaults to domain.DefaultPipSize.
+type SpreadRequest struct {
+	Unit    domain.FXSpreadUnit `json:"unit"`
+	Value   decimal.Decimal     `json:"value"`
+	PipSize *decimal.Decimal    `json:"pip_size"`
+	Note    string              `json:"note"`
+}
+
+// Set creates or replaces the spread taken converting from one currency to
+// the other.
+func (s *Service) Set(ctx context.Context, from, to domain.Currency, req SpreadRequest, by uuid.UUID) (*domain.FXSpread, error) {
+	from, to, err := pair(from, to)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Unit {
+	case domain.FXSpreadPercent:
+		if req.Value.IsNegative() || req.Value.GreaterThan(decimal.NewFromInt(maxPercent)) {
+			return nil, fmt.Errorf("%w: a percentage must be between 0 and %d", ErrInvalidSpread, maxPercent)
+		}
+		if req.PipSize != nil {
+			return nil, fmt.Errorf("%w: pip_size only applies to spreads in pips", ErrInvalidSpread)
+		}
+	case domain.FXSpreadPips:
+		if req.Value.IsNegative() {
+			return nil, fmt.Errorf("%w: pips must not be negative", ErrInvalidSpread)
+		}
+		if req.PipSize != nil && !req.PipSize.IsPositive() {
+			return nil, fmt.Errorf("%w: pip_size must be positive", ErrInvalidSpread)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unit must be percent or pips", ErrInvalidSpread)
+	}
+	note := strings.TrimSpace(req.Note)
+	if len(note) > 500 {
+		return nil, fmt.Errorf("%w: note must be at most 500 characters", ErrInvalidSpread)
+	}
+
+	now := s.now().UTC()
+	sp, err := s.repo.Find(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if sp == nil {
+		sp = &domain.FXSpread{ID: uuid.New(), BaseCurrency: from, TargetCurrency: to, CreatedAt: now}
+	}
+	sp.Unit, sp.Value, sp.PipSize, sp.Note = req.Unit, req.Value, req.PipSize, note
+	sp.UpdatedBy, sp.UpdatedAt = by, now
+	if err := s.repo.Save(ctx, sp); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return sp, nil
+}
+
+// Delete drops a pair's spread; it is converted at the dynamic spread
+// again.
+func (s *Service) Delete(ctx context.Context, from, to domain.Currency) error {
+	from, to, err := pair(from, to)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.repo.Delete(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSpreadNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *Service) List(ctx context.Context) ([]domain.FXSpread, error) {
+	return s.repo.List(ctx)
+}
+
+// SpreadFor returns the spread set for converting from one currency to the
+// other, or nil when the pair has none.
+func (s *Service) SpreadFor(ctx context.Context, from, to domain.Currency) (*domain.FXSpread, error) {
+	cached, err := s.fresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sp, ok := cached[key(from, to)]
+	if !ok {
+		return nil, nil
+	}
+	return &sp, nil
+}
+
+// Report is the FX revenue of a window, per day and corridor and per
+// corridor over the whole window.
+type Report struct {
+	From      time.Time          `json:"from"`
+	To        time.Time          `json:"to"`
+	Days      []domain.FXRevenue `json:"days"`
+	Corridors []domain.FXRevenue `json:"corridors"`
+}
+
+// Revenue reports what spreads earned on payments completed in a window:
+// the last 30 days when From and To are zero. Day rows cover UTC days;
+// corridor totals have no day.
+func (s *Service) Revenue(ctx context.Context, f domain.FXRevenueFilter) (*Report, error) {
+	if f.To.IsZero() {
+		f.To = s.now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	}
+	if f.From.IsZero() {
+		f.From = f.To.AddDate(0, 0, -defaultReportDays)
+	}
+	f.From, f.To = f.From.UTC(), f.To.UTC()
+	if !f.From.Before(f.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidReport)
+	}
+	if f.To.Sub(f.From) > maxReportDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: the window must be at most %d days", ErrInvalidReport, maxReportDays)
+	}
+	if (f.BaseCurrency == "") != (f.TargetCurrency == "") {
+		return nil, fmt.Errorf("%w: give both currencies of a corridor, or neither", ErrInvalidReport)
+	}
+	if f.BaseCurrency != "" {
+		var err error
+		if f.BaseCurrency, f.TargetCurrency, err = pair(f.BaseCurrency, f.TargetCurrency); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidReport, err)
+		}
+	}
+
+	days, err := s.repo.Revenue(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	totals := map[string]*domain.FXRevenue{}
+	for _, d := range days {
+		t, ok := totals[key(d.BaseCurrency, d.TargetCurrency)]
+		if !ok {
+			t = &domain.FXRevenue{BaseCurrency: d.BaseCurrency, TargetCurrency: d.TargetCurrency}
+			totals[key(d.BaseCurrency, d.TargetCurrency)] = t
+		}
+		t.Payments += d.Payments
+		t.Volume = t.Volume.Add(d.Volume)
+		t.Converted = t.Converted.Add(d.Converted)
+		t.Revenue = t.Revenue.Add(d.Revenue)
+		t.BaseRevenue = t.BaseRevenue.Add(d.BaseRevenue)
+	}
+	corridors := make([]domain.FXRevenue, 0, len(totals))
+	for _, t := range totals {
+		corridors = append(corridors, *t)
+	}
+	sort.Slice(corridors, func(i, j int) bool {
+		return key(corridors[i].BaseCurrency, corridors[i].TargetCurrency) < key(corridors[j].BaseCurrency, corridors[j].TargetCurrency)
+	})
+	if days == nil {
+		days = []domain.FXRevenue{}
+	}
+	return &Report{From: f.From, To: f.To, Days: days, Corridors: corridors}, nil
+}
+
+// RevenueCSV is the day rows of the revenue report as CSV.
+func (s *Service) RevenueCSV(ctx context.Context, f domain.FXRevenueFilter) ([]byte, error) {
+	report, err := s.Revenue(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"day", "base_currency", "target_currency", "payments", "volume", "converted", "revenue", "base_revenue"})
+	for _, d := range report.Days {
+		_ = w.Write([]string{
+			d.Day.Format("2006-01-02"),
+			string(d.BaseCurrency),
+			string(d.TargetCurrency),
+			strconv.Itoa(d.Payments),
+			d.Volume.String(),
+			d.Converted.String(),
+			d.Revenue.StringFixed(revenueRoundPlaces),
+			d.BaseRevenue.StringFixed(revenueRoundPlaces),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// pair upper-cases and checks the currencies of a pair.
+func pair(from, to domain.Currency) (domain.Currency, domain.Currency, error) {
+	from = domain.Currency(strings.ToUpper(strings.TrimSpace(string(from))))
+	to = domain.Currency(strings.ToUpper(strings.TrimSpace(string(to))))
+	if len(from) != 3 || len(to) != 3 {
+		return "", "", fmt.Errorf("%w: currencies must be 3-letter codes", ErrInvalidSpread)
+	}
+	if from == to {
+		return "", "", fmt.Errorf("%w: the currencies must differ", ErrInvalidSpread)
+	}
+	return from, to, nil
+}
+
+func key(from, to domain.Currency) string {
+	return string(from) + "-" + string(to)
+}
+
+// fresh returns the cached spreads by pair, reloading them once the TTL
+// has passed.
+func (s *Service) fresh(ctx context.Context) (map[string]domain.FXSpread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && s.now().Sub(s.loadedAt) < s.ttl {
+		return s.cache, nil
+	}
+	spreads, err := s.repo.List(ctx)
+	if err != nil {
+		if s.cache != nil {
+			// Serve the last good copy rather than fail every conversion.
+			s.logger.Warn("Failed to refresh fx spreads, serving cached copy", map[string]interface{}{"error": err.Error()})
+			return s.cache, nil
+		}
+		return nil, err
+	}
+	cache := make(map[string]domain.FXSpread, len(spreads))
+	for _, sp := range spreads {
+		cache[key(sp.BaseCurrency, sp.TargetCurrency)] = sp
+	}
+	s.cache = cache
+	s.loadedAt = s.now()
+	return cache, nil
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,220 @@
+package spreads
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Save(ctx context.Context, s *domain.FXSpread) error {
+	return m.Called(ctx, s).Error(0)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, from, to domain.Currency) (bool, error) {
+	args := m.Called(ctx, from, to)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Find(ctx context.Context, from, to domain.Currency) (*domain.FXSpread, error) {
+	args := m.Called(ctx, from, to)
+	sp, _ := args.Get(0).(*domain.FXSpread)
+	if sp != nil {
+		cp := *sp
+		sp = &cp
+	}
+	return sp, args.Error(1)
+}
+
+func (m *MockRepository) List(ctx context.Context) ([]domain.FXSpread, error) {
+	args := m.Called(ctx)
+	spreads, _ := args.Get(0).([]domain.FXSpread)
+	return spreads, args.Error(1)
+}
+
+func (m *MockRepository) Revenue(ctx context.Context, f domain.FXRevenueFilter) ([]domain.FXRevenue, error) {
+	args := m.Called(ctx, f)
+	revenue, _ := args.Get(0).([]domain.FXRevenue)
+	return revenue, args.Error(1)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+)
+
+func newService(now *time.Time) (*Service, *MockRepository) {
+	repo := &MockRepository{}
+	s := NewService(repo, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo
+}
+
+func dec(v string) decimal.Decimal {
+	return decimal.RequireFromString(v)
+}
+
+func TestSetValidates(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	pip := dec("0.01")
+	zero := decimal.Zero
+
+	for name, tc := range map[string]struct {
+		from, to domain.Currency
+		req      SpreadRequest
+	}{
+		"same currency":    {"MWK", "mwk", SpreadRequest{Unit: domain.FXSpreadPercent, Value: dec("1")}},
+		"not a code":       {"MW", "ZAR", SpreadRequest{Unit: domain.FXSpreadPercent, Value: dec("1")}},
+		"unknown unit":     {"MWK", "ZAR", SpreadRequest{Unit: "bps", Value: dec("1")}},
+		"negative percent": {"MWK", "ZAR", SpreadRequest{Unit: domain.FXSpreadPercent, Value: dec("-1")}},
+		"percent too wide": {"MWK", "ZAR", SpreadRequest{Unit: domain.FXSpreadPercent, Value: dec("10.5")}},
+		"percent with pip": {"MWK", "ZAR", SpreadRequest{Unit: domain.FXSpreadPercent, Value: dec("1"), PipSize: &pip}},
+		"negative pips":    {"MWK", "ZAR", SpreadRequest{Unit: domain.FXSpreadPips, Value: dec("-5")}},
+		"zero pip size":    {"MWK", "ZAR", SpreadRequest{Unit: domain.FXSpreadPips, Value: dec("5"), PipSize: &zero}},
+		"note too long":    {"MWK", "ZAR", SpreadRequest{Unit: domain.FXSpreadPips, Value: dec("5"), Note: strings.Repeat("x", 501)}},
+	} {
+		_, err := s.Set(ctx, tc.from, tc.to, tc.req, uuid.New())
+		assert.ErrorIs(t, err, ErrInvalidSpread, name)
+	}
+	repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+
+	admin := uuid.New()
+	repo.On("Find", ctx, domain.MWK, domain.ZAR).Return(nil, nil).Once()
+	repo.On("Save", ctx, mock.MatchedBy(func(sp *domain.FXSpread) bool {
+		return sp.UpdatedBy == admin && sp.CreatedAt.Equal(now) && sp.UpdatedAt.Equal(now)
+	})).Return(nil).Once()
+	sp, err := s.Set(ctx, " mwk", "zar", SpreadRequest{Unit: domain.FXSpreadPips, Value: dec("25"), PipSize: &pip, Note: " corridor launch "}, admin)
+	require.NoError(t, err)
+	assert.Equal(t, domain.MWK, sp.BaseCurrency)
+	assert.Equal(t, domain.ZAR, sp.TargetCurrency)
+	assert.Equal(t, "corridor launch", sp.Note)
+	assert.Equal(t, "0.25", sp.Margin(dec("2")).String())
+	repo.AssertExpectations(t)
+}
+
+func TestSetReplacesAndCaches(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	admin := uuid.New()
+
+	var saved *domain.FXSpread
+	repo.On("Find", ctx, domain.MWK, domain.ZAR).Return(nil, nil).Once()
+	repo.On("Save", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*domain.FXSpread)
+	}).Return(nil).Once()
+	first, err := s.Set(ctx, domain.MWK, domain.ZAR, SpreadRequest{Unit: domain.FXSpreadPercent, Value: dec("1")}, admin)
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+
+	repo.On("List", ctx).Return([]domain.FXSpread{*saved}, nil).Once()
+	sp, err := s.SpreadFor(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	require.NotNil(t, sp)
+	assert.Equal(t, "1", sp.Value.String())
+	none, err := s.SpreadFor(ctx, domain.ZAR, domain.MWK)
+	require.NoError(t, err)
+	assert.Nil(t, none, "spreads apply one way only")
+	repo.AssertExpectations(t)
+
+	now = now.Add(time.Hour)
+	repo.On("Find", ctx, domain.MWK, domain.ZAR).Return(first, nil).Once()
+	repo.On("Save", ctx, mock.MatchedBy(func(sp *domain.FXSpread) bool {
+		return sp.ID == first.ID && sp.CreatedAt.Equal(first.CreatedAt) && sp.UpdatedAt.Equal(now) && sp.Value.String() == "2"
+	})).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*domain.FXSpread)
+	}).Return(nil).Once()
+	second, err := s.Set(ctx, domain.MWK, domain.ZAR, SpreadRequest{Unit: domain.FXSpreadPercent, Value: dec("2")}, admin)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID, "replaced, not added")
+	repo.On("List", ctx).Return([]domain.FXSpread{*saved}, nil).Once()
+	sp, err = s.SpreadFor(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Equal(t, "2", sp.Value.String(), "a write applies at once")
+	repo.AssertExpectations(t)
+
+	// Past the TTL a failed reload serves the last good copy.
+	now = now.Add(2 * defaultCacheTTL)
+	repo.On("List", ctx).Return(nil, errors.New("db down")).Once()
+	sp, err = s.SpreadFor(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Equal(t, "2", sp.Value.String())
+	repo.AssertExpectations(t)
+
+	repo.On("Delete", ctx, domain.MWK, domain.ZAR).Return(true, nil).Once()
+	repo.On("List", ctx).Return(nil, nil).Once()
+	require.NoError(t, s.Delete(ctx, "mwk", "zar"))
+	sp, err = s.SpreadFor(ctx, domain.MWK, domain.ZAR)
+	require.NoError(t, err)
+	assert.Nil(t, sp)
+	repo.On("Delete", ctx, domain.MWK, domain.ZAR).Return(false, nil).Once()
+	assert.ErrorIs(t, s.Delete(ctx, domain.MWK, domain.ZAR), ErrSpreadNotFound)
+	repo.AssertExpectations(t)
+
+	t.Run("nothing to serve", func(t *testing.T) {
+		s, repo := newService(&now)
+		repo.On("List", ctx).Return(nil, errors.New("db down")).Once()
+		_, err := s.SpreadFor(ctx, domain.MWK, domain.ZAR)
+		assert.Error(t, err)
+	})
+}
+
+func TestRevenue(t *testing.T) {
+	now := start
+	s, repo := newService(&now)
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	revenue := []domain.FXRevenue{
+		{Day: day, BaseCurrency: domain.MWK, TargetCurrency: domain.ZAR, Payments: 2, Volume: dec("1000"), Converted: dec("10.5"), Revenue: dec("0.5"), BaseRevenue: dec("45.45")},
+		{Day: day, BaseCurrency: domain.ZAR, TargetCurrency: domain.MWK, Payments: 1, Volume: dec("10"), Converted: dec("950"), Revenue: dec("50"), BaseRevenue: dec("0.5")},
+		{Day: day.AddDate(0, 0, 1), BaseCurrency: domain.MWK, TargetCurrency: domain.ZAR, Payments: 1, Volume: dec("500"), Converted: dec("5.25"), Revenue: dec("0.25"), BaseRevenue: dec("22.73")},
+	}
+	lastMonth := domain.FXRevenueFilter{From: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)}
+	repo.On("Revenue", ctx, lastMonth).Return(revenue, nil).Twice()
+
+	report, err := s.Revenue(ctx, domain.FXRevenueFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, lastMonth.To, report.To, "through the end of today")
+	assert.Equal(t, lastMonth.From, report.From)
+	assert.Len(t, report.Days, 3)
+	require.Len(t, report.Corridors, 2)
+	assert.Equal(t, domain.MWK, report.Corridors[0].BaseCurrency)
+	assert.Equal(t, 3, report.Corridors[0].Payments)
+	assert.Equal(t, "0.75", report.Corridors[0].Revenue.String())
+	assert.Equal(t, "68.18", report.Corridors[0].BaseRevenue.String())
+	assert.Equal(t, "50", report.Corridors[1].Revenue.String())
+
+	_, err = s.Revenue(ctx, domain.FXRevenueFilter{From: now, To: now.Add(-time.Hour)})
+	assert.ErrorIs(t, err, ErrInvalidReport)
+	_, err = s.Revenue(ctx, domain.FXRevenueFilter{From: now.AddDate(-2, 0, 0), To: now})
+	assert.ErrorIs(t, err, ErrInvalidReport, "too long")
+	_, err = s.Revenue(ctx, domain.FXRevenueFilter{BaseCurrency: domain.MWK})
+	assert.ErrorIs(t, err, ErrInvalidReport, "half a corridor")
+
+	corridor := lastMonth
+	corridor.BaseCurrency, corridor.TargetCurrency = domain.MWK, domain.ZAR
+	repo.On("Revenue", ctx, corridor).Return(nil, nil).Once()
+	report, err = s.Revenue(ctx, domain.FXRevenueFilter{BaseCurrency: "mwk", TargetCurrency: "zar"})
+	require.NoError(t, err)
+	assert.NotNil(t, report.Days, "listed as empty")
+
+	content, err := s.RevenueCSV(ctx, domain.FXRevenueFilter{})
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "2026-03-01,MWK,ZAR,2,1000,10.5,0.50,45.45", lines[1])
+	repo.AssertExpectations(t)
+}
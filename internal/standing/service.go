@@ -0,0 +1,581 @@
+// Package standing runs customers' standing instructions on incoming funds.
+//
+// An instruction watches one of a customer's wallets. auto_convert converts
+// each credit that settles into the wallet to another of the customer's
+// currencies, through the payment service like any other conversion.
+// auto_sweep brings the balance back down to a threshold after each credit,
+// setting the rest aside in one of the wallet's savings pockets. The worker
+// looks for new credits every interval and claims each before acting on it,
+// so that it is acted on once however many instances run. Money moved by an
+// instruction never triggers another.
+package standing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInstructionNotFound = errors.New("standing instruction not found")
+	ErrInstructionExists   = errors.New("wallet already has an active standing instruction")
+	ErrInvalidInstruction  = errors.New("invalid standing instruction")
+	ErrPocketNotFound      = errors.New("savings pocket not found")
+	ErrPocketExists        = errors.New("wallet already has a pocket with that name")
+	ErrInvalidPocket       = errors.New("invalid savings pocket")
+	ErrPocketBalance       = errors.New("not enough money in the pocket")
+	ErrRunInProgress       = errors.New("a standing instruction run is already in progress")
+)
+
+// Category marks the payments instructions make, which are never acted on
+// themselves.
+const Category = "STANDING_INSTRUCTION"
+
+// claimTimeout is how long a claimed credit may stay pending before another
+// run takes it over. Conversions carry the claim's reference, so one that
+// went through before the claim was abandoned is not made twice.
+const claimTimeout = 15 * time.Minute
+
+// maxPocketName bounds a pocket's name.
+const maxPocketName = 50
+
+// Notification event types.
+const (
+	EventFundsSwept        = "FUNDS_SWEPT"
+	EventInstructionFailed = "STANDING_INSTRUCTION_FAILED"
+)
+
+type Repository interface {
+	// CreateInstruction returns ErrInstructionExists when the wallet has an
+	// active instruction.
+	CreateInstruction(ctx context.Context, in *domain.StandingInstruction) error
+	GetInstruction(ctx context.Context, id uuid.UUID) (*domain.StandingInstruction, error)
+	ListInstructions(ctx context.Context, userID uuid.UUID) ([]domain.StandingInstruction, error)
+	// UpdateInstruction saves in's threshold and whether it is active. It
+	// returns ErrInstructionExists when activating it would give the
+	// wallet a second active instruction.
+	UpdateInstruction(ctx context.Context, in *domain.StandingInstruction) error
+	DeleteInstruction(ctx context.Context, id uuid.UUID) error
+	ListExecutions(ctx context.Context, instructionID uuid.UUID, limit, offset int) ([]domain.StandingInstructionExecution, int, error)
+
+	// PendingCredits returns the payments and top-ups that settled into a
+	// wallet after its active instruction was turned on and that the
+	// instruction has not claimed, or whose claim has been pending since
+	// before stale. Payments made by instructions are left out.
+	PendingCredits(ctx context.Context, stale time.Time, limit int) ([]domain.IncomingCredit, error)
+	// ClaimCredit saves e as pending. It returns false when another run
+	// holds the claim; taking over a stale claim keeps its ID, which is
+	// copied to e.
+	ClaimCredit(ctx context.Context, e *domain.StandingInstructionExecution, stale time.Time) (bool, error)
+	// FinishExecution saves e's outcome.
+	FinishExecution(ctx context.Context, e *domain.StandingInstructionExecution) error
+
+	// CreatePocket returns ErrPocketExists when the wallet has a pocket of
+	// the same name.
+	CreatePocket(ctx context.Context, p *domain.SavingsPocket) error
+	GetPocket(ctx context.Context, id uuid.UUID) (*domain.SavingsPocket, error)
+	ListPockets(ctx context.Context, userID uuid.UUID) ([]domain.SavingsPocket, error)
+	// SweepToPocket moves the wallet's available balance above keep into
+	// the pocket, atomically, and returns the pocket and how much moved.
+	SweepToPocket(ctx context.Context, pocketID uuid.UUID, keep decimal.Decimal) (*domain.SavingsPocket, decimal.Decimal, error)
+	// WithdrawFromPocket moves amount back to the wallet's available
+	// balance. It returns ErrPocketBalance when the pocket holds less.
+	WithdrawFromPocket(ctx context.Context, pocketID uuid.UUID, amount decimal.Decimal) (*domain.SavingsPocket, error)
+}
+
+// Conversion moves Amount of a customer's money from their wallet in From
+// to their wallet in To. Reference makes it idempotent.
+type Conversion struct {
+	UserID      uuid.UUID
+	Amount      decimal.Decimal
+	From        domain.Currency
+	To          domain.Currency
+	Reference   string
+	Description string
+	Metadata    domain.Metadata
+}
+
+// Payments converts money between a customer's wallets, as a payment in
+// Category from the system rather than a device of the customer's.
+type Payments interface {
+	Convert(ctx context.Context, c *Conversion) (*domain.Transaction, error)
+	// FeeRate is the fee charged on top of what userID converts, as a
+	// fraction of it.
+	FeeRate(ctx context.Context, userID uuid.UUID) (decimal.Decimal, error)
+}
+
+// WalletFinder returns nil when the user has no wallet in currency.
+type WalletFinder interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error)
+	FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error)
+}
+
+// Notifier tells customers what their instructions did.
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error
+}
+
+type Service struct {
+	repo     Repository
+	payments Payments
+	wallets  WalletFinder
+	notifier Notifier
+	cfg      config.StandingConfig
+	logger   logger.Logger
+	now      func() time.Time
+
+	running  sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, payments Payments, wallets WalletFinder, notifier Notifier, cfg config.StandingConfig, log logger.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		payments: payments,
+		wallets:  wallets,
+		notifier: notifier,
+		cfg:      cfg,
+		logger:   log,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start acts on new credits every configured interval until Stop is
+// called.
+func (s *Service) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := s.Run(context.Background()); err != nil && !errors.Is(err, ErrRunInProgress) {
+					s.logger.Error("Standing instruction run failed", map[string]interface{}{"error": err.Error()})
+				}
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// CreateRequest sets up an instruction on the caller's wallet in Currency.
+// An auto_convert instruction needs TargetCurrency, in which the caller
+// must have a wallet too; an auto_sweep one needs PocketID, a pocket of the
+// same wallet.
+type CreateRequest struct {
+	Currency       domain.Currency                `json:"currency" validate:"required"`
+	Kind           domain.StandingInstructionKind `json:"kind" validate:"required"`
+	TargetCurrency domain.Currency                `json:"target_currency"`
+	PocketID       *uuid.UUID                     `json:"pocket_id"`
+	Threshold      decimal.Decimal                `json:"threshold"`
+}
+
+// Create saves a new, active instruction. It acts on credits that settle
+// from now on.
+func (s *Service) Create(ctx context.Context, userID uuid.UUID, req *CreateRequest) (*domain.StandingInstruction, error) {
+	if req.Threshold.IsNegative() {
+		return nil, fmt.Errorf("%w: threshold must not be negative", ErrInvalidInstruction)
+	}
+	wallet, err := s.wallets.FindByUserAndCurrency(ctx, userID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, fmt.Errorf("%w: you have no %s wallet", ErrInvalidInstruction, req.Currency)
+	}
+
+	now := s.now().UTC()
+	in := &domain.StandingInstruction{
+		ID:          uuid.New(),
+		UserID:      userID,
+		WalletID:    wallet.ID,
+		Currency:    wallet.Currency,
+		Kind:        req.Kind,
+		Threshold:   req.Threshold.Round(2),
+		Active:      true,
+		ActiveSince: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	switch req.Kind {
+	case domain.StandingInstructionConvert:
+		if req.PocketID != nil {
+			return nil, fmt.Errorf("%w: auto_convert does not take a pocket", ErrInvalidInstruction)
+		}
+		if req.TargetCurrency == "" || req.TargetCurrency == wallet.Currency {
+			return nil, fmt.Errorf("%w: target_currency must be another currency", ErrInvalidInstruction)
+		}
+		target, err := s.wallets.FindByUserAndCurrency(ctx, userID, req.TargetCurrency)
+		if err != nil {
+			return nil, err
+		}
+		if target == nil {
+			return nil, fmt.Errorf("%w: you have no %s wallet", ErrInvalidInstruction, req.TargetCurrency)
+		}
+		currency := target.Currency
+		in.TargetCurrency = &currency
+	case domain.StandingInstructionSweep:
+		if req.TargetCurrency != "" {
+			return nil, fmt.Errorf("%w: auto_sweep does not take a target_currency", ErrInvalidInstruction)
+		}
+		if req.PocketID == nil {
+			return nil, fmt.Errorf("%w: pocket_id is required", ErrInvalidInstruction)
+		}
+		pocket, err := s.repo.GetPocket(ctx, *req.PocketID)
+		if errors.Is(err, ErrPocketNotFound) || (err == nil && pocket.UserID != userID) {
+			return nil, fmt.Errorf("%w: unknown pocket", ErrInvalidInstruction)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pocket.WalletID != wallet.ID {
+			return nil, fmt.Errorf("%w: the pocket is not in your %s wallet", ErrInvalidInstruction, wallet.Currency)
+		}
+		in.PocketID = &pocket.ID
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidInstruction, req.Kind)
+	}
+
+	if err := s.repo.CreateInstruction(ctx, in); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Standing instruction created", map[string]interface{}{
+		"instruction_id": in.ID,
+		"user_id":        userID,
+		"wallet_id":      in.WalletID,
+		"kind":           in.Kind,
+	})
+	return in, nil
+}
+
+// UpdateRequest changes an instruction; nil fields stay as they are.
+type UpdateRequest struct {
+	Threshold *decimal.Decimal `json:"threshold"`
+	Active    *bool            `json:"active"`
+}
+
+// Update changes the caller's instruction. Turning it back on acts on
+// credits that settle from then on, not those that came in while it was
+// off.
+func (s *Service) Update(ctx context.Context, userID, id uuid.UUID, req *UpdateRequest) (*domain.StandingInstruction, error) {
+	in, err := s.instruction(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	if req.Threshold != nil {
+		if req.Threshold.IsNegative() {
+			return nil, fmt.Errorf("%w: threshold must not be negative", ErrInvalidInstruction)
+		}
+		in.Threshold = req.Threshold.Round(2)
+	}
+	if req.Active != nil {
+		if *req.Active && !in.Active {
+			in.ActiveSince = now
+		}
+		in.Active = *req.Active
+	}
+	in.UpdatedAt = now
+	if err := s.repo.UpdateInstruction(ctx, in); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// Delete removes the caller's instruction and its history.
+func (s *Service) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := s.instruction(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteInstruction(ctx, id)
+}
+
+// Instructions lists the caller's instructions, inactive ones included.
+func (s *Service) Instructions(ctx context.Context, userID uuid.UUID) ([]domain.StandingInstruction, error) {
+	return s.repo.ListInstructions(ctx, userID)
+}
+
+// Executions lists what the caller's instruction did, newest first.
+func (s *Service) Executions(ctx context.Context, userID, id uuid.UUID, limit, offset int) ([]domain.StandingInstructionExecution, int, error) {
+	if _, err := s.instruction(ctx, userID, id); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListExecutions(ctx, id, limit, offset)
+}
+
+// instruction returns userID's instruction id; other users' instructions
+// are not found.
+func (s *Service) instruction(ctx context.Context, userID, id uuid.UUID) (*domain.StandingInstruction, error) {
+	in, err := s.repo.GetInstruction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if in.UserID != userID {
+		return nil, ErrInstructionNotFound
+	}
+	return in, nil
+}
+
+// CreatePocketRequest opens a pocket in the caller's wallet in Currency.
+type CreatePocketRequest struct {
+	Currency domain.Currency `json:"currency" validate:"required"`
+	Name     string          `json:"name" validate:"required"`
+}
+
+// CreatePocket opens an empty pocket.
+func (s *Service) CreatePocket(ctx context.Context, userID uuid.UUID, req *CreatePocketRequest) (*domain.SavingsPocket, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > maxPocketName {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidPocket, maxPocketName)
+	}
+	wallet, err := s.wallets.FindByUserAndCurrency(ctx, userID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, fmt.Errorf("%w: you have no %s wallet", ErrInvalidPocket, req.Currency)
+	}
+	now := s.now().UTC()
+	p := &domain.SavingsPocket{
+		ID:        uuid.New(),
+		UserID:    userID,
+		WalletID:  wallet.ID,
+		Currency:  wallet.Currency,
+		Name:      name,
+		Balance:   decimal.Zero,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.CreatePocket(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Pockets lists the caller's pockets.
+func (s *Service) Pockets(ctx context.Context, userID uuid.UUID) ([]domain.SavingsPocket, error) {
+	return s.repo.ListPockets(ctx, userID)
+}
+
+// WithdrawFromPocket gives amount from the caller's pocket back to its
+// wallet to spend.
+func (s *Service) WithdrawFromPocket(ctx context.Context, userID, pocketID uuid.UUID, amount decimal.Decimal) (*domain.SavingsPocket, error) {
+	amount = amount.Round(2)
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidPocket)
+	}
+	p, err := s.repo.GetPocket(ctx, pocketID)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID != userID {
+		return nil, ErrPocketNotFound
+	}
+	return s.repo.WithdrawFromPocket(ctx, pocketID, amount)
+}
+
+// Run acts on the credits that have settled since the last run.
+func (s *Service) Run(ctx context.Context) (*domain.StandingInstructionRun, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer s.running.Unlock()
+
+	stale := s.now().Add(-claimTimeout)
+	credits, err := s.repo.PendingCredits(ctx, stale, s.cfg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	run := &domain.StandingInstructionRun{Examined: len(credits)}
+	instructions := make(map[uuid.UUID]*domain.StandingInstruction)
+	for _, c := range credits {
+		in, ok := instructions[c.InstructionID]
+		if !ok {
+			if in, err = s.repo.GetInstruction(ctx, c.InstructionID); err != nil {
+				run.Failed++
+				s.logger.Error("Failed to load standing instruction", map[string]interface{}{
+					"instruction_id": c.InstructionID,
+					"error":          err.Error(),
+				})
+				continue
+			}
+			instructions[c.InstructionID] = in
+		}
+		e, err := s.act(ctx, in, c, stale)
+		if err != nil {
+			run.Failed++
+			s.logger.Error("Failed to act on incoming credit", map[string]interface{}{
+				"instruction_id": in.ID,
+				"transaction_id": c.TransactionID,
+				"error":          err.Error(),
+			})
+			continue
+		}
+		if e == nil {
+			continue
+		}
+		switch e.Status {
+		case domain.StandingExecutionExecuted:
+			run.Executed++
+		case domain.StandingExecutionSkipped:
+			run.Skipped++
+		case domain.StandingExecutionFailed:
+			run.Failed++
+		}
+	}
+	return run, nil
+}
+
+// act claims credit c for instruction in and carries the instruction out.
+// It returns nil when another run holds the claim. An instruction that
+// cannot be carried out is recorded as failed and the customer told; the
+// error returned is for what could not be recorded.
+func (s *Service) act(ctx context.Context, in *domain.StandingInstruction, c domain.IncomingCredit, stale time.Time) (*domain.StandingInstructionExecution, error) {
+	now := s.now().UTC()
+	e := &domain.StandingInstructionExecution{
+		ID:                   uuid.New(),
+		InstructionID:        in.ID,
+		TriggerTransactionID: c.TransactionID,
+		Status:               domain.StandingExecutionPending,
+		Amount:               decimal.Zero,
+		ClaimedAt:            now,
+		CreatedAt:            now,
+	}
+	claimed, err := s.repo.ClaimCredit(ctx, e, stale)
+	if err != nil || !claimed {
+		return nil, err
+	}
+
+	switch in.Kind {
+	case domain.StandingInstructionConvert:
+		err = s.convert(ctx, in, c, e)
+	case domain.StandingInstructionSweep:
+		err = s.sweep(ctx, in, e)
+	default:
+		err = fmt.Errorf("unknown kind %q", in.Kind)
+	}
+	if err != nil {
+		e.Status = domain.StandingExecutionFailed
+		e.Reason = err.Error()
+		s.logger.Warn("Standing instruction failed", map[string]interface{}{
+			"instruction_id": in.ID,
+			"transaction_id": c.TransactionID,
+			"error":          err.Error(),
+		})
+		s.notify(ctx, in.UserID, EventInstructionFailed, map[string]interface{}{
+			"currency": in.Currency,
+			"reason":   err.Error(),
+		})
+	}
+	return e, s.repo.FinishExecution(ctx, e)
+}
+
+// convert converts credit c to the instruction's target currency. The fee
+// is charged on top of the amount converted; when the rest of the wallet
+// cannot pay it, less is converted so that the credit pays it.
+func (s *Service) convert(ctx context.Context, in *domain.StandingInstruction, c domain.IncomingCredit, e *domain.StandingInstructionExecution) error {
+	if c.Amount.LessThan(in.Threshold) {
+		e.Status = domain.StandingExecutionSkipped
+		e.Reason = fmt.Sprintf("below the threshold of %s %s", in.Threshold, in.Currency)
+		return nil
+	}
+	wallet, err := s.wallets.FindByID(ctx, in.WalletID)
+	if err != nil {
+		return err
+	}
+	if wallet == nil {
+		return fmt.Errorf("the %s wallet is gone", in.Currency)
+	}
+	rate, err := s.payments.FeeRate(ctx, in.UserID)
+	if err != nil {
+		return err
+	}
+	amount := c.Amount
+	if affordable := wallet.AvailableBalance.Div(decimal.NewFromInt(1).Add(rate)).RoundDown(2); affordable.LessThan(amount) {
+		amount = affordable
+	}
+	if !amount.IsPositive() {
+		e.Status = domain.StandingExecutionSkipped
+		e.Reason = "the money has already been spent"
+		return nil
+	}
+
+	tx, err := s.payments.Convert(ctx, &Conversion{
+		UserID: in.UserID,
+		Amount: amount,
+		From:   in.Currency,
+		To:     *in.TargetCurrency,
+		// A claim taken over keeps its ID, so a retry finds the
+		// conversion rather than making another
+		Reference:   "SI-" + e.ID.String(),
+		Description: fmt.Sprintf("Auto-convert to %s", *in.TargetCurrency),
+		Metadata: domain.Metadata{
+			"source":                  "standing_instruction",
+			"standing_instruction_id": in.ID.String(),
+			"trigger_transaction_id":  c.TransactionID.String(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	e.Status = domain.StandingExecutionExecuted
+	e.Amount = amount
+	e.TransactionID = &tx.ID
+	return nil
+}
+
+// sweep sets the wallet's balance above the threshold aside in the
+// instruction's pocket.
+func (s *Service) sweep(ctx context.Context, in *domain.StandingInstruction, e *domain.StandingInstructionExecution) error {
+	pocket, moved, err := s.repo.SweepToPocket(ctx, *in.PocketID, in.Threshold)
+	if err != nil {
+		return err
+	}
+	if !moved.IsPositive() {
+		e.Status = domain.StandingExecutionSkipped
+		e.Reason = fmt.Sprintf("the balance is not above %s %s", in.Threshold, in.Currency)
+		return nil
+	}
+	e.Status = domain.StandingExecutionExecuted
+	e.Amount = moved
+	s.notify(ctx, in.UserID, EventFundsSwept, map[string]interface{}{
+		"amount":   moved.String(),
+		"currency": in.Currency,
+		"pocket":   pocket.Name,
+		"balance":  pocket.Balance.String(),
+	})
+	return nil
+}
+
+func (s *Service) notify(ctx context.Context, userID uuid.UUID, event string, data map[string]interface{}) {
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.Notify(ctx, userID, event, data); err != nil {
+		s.logger.Warn("Failed to send standing instruction notification", map[string]interface{}{
+			"user_id": userID,
+			"event":   event,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,522 @@
+package standing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateInstruction(ctx context.Context, in *domain.StandingInstruction) error {
+	return m.Called(ctx, in).Error(0)
+}
+
+func (m *MockRepository) GetInstruction(ctx context.Context, id uuid.UUID) (*domain.StandingInstruction, error) {
+	args := m.Called(ctx, id)
+	in, _ := args.Get(0).(*domain.StandingInstruction)
+	if in == nil {
+		return nil, args.Error(1)
+	}
+	copied := *in
+	return &copied, args.Error(1)
+}
+
+func (m *MockRepository) ListInstructions(ctx context.Context, userID uuid.UUID) ([]domain.StandingInstruction, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.StandingInstruction), args.Error(1)
+}
+
+func (m *MockRepository) UpdateInstruction(ctx context.Context, in *domain.StandingInstruction) error {
+	return m.Called(ctx, in).Error(0)
+}
+
+func (m *MockRepository) DeleteInstruction(ctx context.Context, id uuid.UUID) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockRepository) ListExecutions(ctx context.Context, instructionID uuid.UUID, limit, offset int) ([]domain.StandingInstructionExecution, int, error) {
+	args := m.Called(ctx, instructionID, limit, offset)
+	return args.Get(0).([]domain.StandingInstructionExecution), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) PendingCredits(ctx context.Context, stale time.Time, limit int) ([]domain.IncomingCredit, error) {
+	args := m.Called(ctx, stale, limit)
+	return args.Get(0).([]domain.IncomingCredit), args.Error(1)
+}
+
+// ClaimCredit gives e the ID of the claim it takes over, when there is one.
+func (m *MockRepository) ClaimCredit(ctx context.Context, e *domain.StandingInstructionExecution, stale time.Time) (bool, error) {
+	args := m.Called(ctx, e, stale)
+	if id, ok := args.Get(2).(uuid.UUID); ok {
+		e.ID = id
+	}
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) FinishExecution(ctx context.Context, e *domain.StandingInstructionExecution) error {
+	return m.Called(ctx, e).Error(0)
+}
+
+func (m *MockRepository) CreatePocket(ctx context.Context, p *domain.SavingsPocket) error {
+	return m.Called(ctx, p).Error(0)
+}
+
+func (m *MockRepository) GetPocket(ctx context.Context, id uuid.UUID) (*domain.SavingsPocket, error) {
+	args := m.Called(ctx, id)
+	p, _ := args.Get(0).(*domain.SavingsPocket)
+	return p, args.Error(1)
+}
+
+func (m *MockRepository) ListPockets(ctx context.Context, userID uuid.UUID) ([]domain.SavingsPocket, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.SavingsPocket), args.Error(1)
+}
+
+func (m *MockRepository) SweepToPocket(ctx context.Context, pocketID uuid.UUID, keep decimal.Decimal) (*domain.SavingsPocket, decimal.Decimal, error) {
+	args := m.Called(ctx, pocketID, keep)
+	p, _ := args.Get(0).(*domain.SavingsPocket)
+	return p, args.Get(1).(decimal.Decimal), args.Error(2)
+}
+
+func (m *MockRepository) WithdrawFromPocket(ctx context.Context, pocketID uuid.UUID, amount decimal.Decimal) (*domain.SavingsPocket, error) {
+	args := m.Called(ctx, pocketID, amount)
+	p, _ := args.Get(0).(*domain.SavingsPocket)
+	return p, args.Error(1)
+}
+
+type MockPayments struct {
+	mock.Mock
+}
+
+func (m *MockPayments) Convert(ctx context.Context, c *Conversion) (*domain.Transaction, error) {
+	args := m.Called(ctx, c)
+	tx, _ := args.Get(0).(*domain.Transaction)
+	return tx, args.Error(1)
+}
+
+func (m *MockPayments) FeeRate(ctx context.Context, userID uuid.UUID) (decimal.Decimal, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+type MockWallets struct {
+	mock.Mock
+}
+
+func (m *MockWallets) FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	args := m.Called(ctx, id)
+	w, _ := args.Get(0).(*domain.Wallet)
+	return w, args.Error(1)
+}
+
+func (m *MockWallets) FindByUserAndCurrency(ctx context.Context, userID uuid.UUID, currency domain.Currency) (*domain.Wallet, error) {
+	args := m.Called(ctx, userID, currency)
+	w, _ := args.Get(0).(*domain.Wallet)
+	return w, args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, userID uuid.UUID, eventType string, data map[string]interface{}) error {
+	return m.Called(ctx, userID, eventType, data).Error(0)
+}
+
+type mocks struct {
+	repo     *MockRepository
+	payments *MockPayments
+	wallets  *MockWallets
+	notifier *MockNotifier
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.payments.AssertExpectations(t)
+	m.wallets.AssertExpectations(t)
+	m.notifier.AssertExpectations(t)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	// feeRate is charged on top of what is converted.
+	feeRate = decimal.RequireFromString("0.015")
+)
+
+func newService(now *time.Time) (*Service, *mocks) {
+	m := &mocks{new(MockRepository), new(MockPayments), new(MockWallets), new(MockNotifier)}
+	cfg := config.StandingConfig{Enabled: true, Interval: time.Minute, BatchSize: 100}
+	s := NewService(m.repo, m.payments, m.wallets, m.notifier, cfg, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, m
+}
+
+// wallets is a customer's CNY and MWK wallets.
+func wallets() (userID uuid.UUID, cny, mwk *domain.Wallet) {
+	userID = uuid.New()
+	cny = &domain.Wallet{ID: uuid.New(), UserID: userID, Currency: domain.CNY, Status: domain.WalletStatusActive}
+	mwk = &domain.Wallet{ID: uuid.New(), UserID: userID, Currency: domain.MWK, Status: domain.WalletStatusActive}
+	return userID, cny, mwk
+}
+
+func convertInstruction(w *domain.Wallet, threshold int64) *domain.StandingInstruction {
+	target := domain.MWK
+	return &domain.StandingInstruction{
+		ID: uuid.New(), UserID: w.UserID, WalletID: w.ID, Currency: w.Currency, Kind: domain.StandingInstructionConvert,
+		TargetCurrency: &target, Threshold: decimal.NewFromInt(threshold), Active: true, ActiveSince: start,
+	}
+}
+
+// amount matches a decimal equal to s.
+func amount(s string) interface{} {
+	return mock.MatchedBy(func(d decimal.Decimal) bool { return d.Equal(decimal.RequireFromString(s)) })
+}
+
+// execution matches a finished execution.
+func execution(status domain.StandingExecutionStatus, amount string) interface{} {
+	return mock.MatchedBy(func(e *domain.StandingInstructionExecution) bool {
+		return e.Status == status && e.Amount.String() == amount
+	})
+}
+
+func TestCreateValidates(t *testing.T) {
+	userID, cny, mwk := wallets()
+	pocket := &domain.SavingsPocket{ID: uuid.New(), UserID: userID, WalletID: mwk.ID, Currency: domain.MWK}
+	other := &domain.SavingsPocket{ID: uuid.New(), UserID: uuid.New(), WalletID: cny.ID}
+
+	tests := []struct {
+		name string
+		req  CreateRequest
+	}{
+		{"negative threshold", CreateRequest{Currency: domain.CNY, Kind: domain.StandingInstructionConvert, TargetCurrency: domain.MWK, Threshold: decimal.NewFromInt(-1)}},
+		{"no wallet in the currency", CreateRequest{Currency: domain.ZMW, Kind: domain.StandingInstructionConvert, TargetCurrency: domain.MWK}},
+		{"converting to the same currency", CreateRequest{Currency: domain.CNY, Kind: domain.StandingInstructionConvert, TargetCurrency: domain.CNY}},
+		{"no wallet in the target currency", CreateRequest{Currency: domain.CNY, Kind: domain.StandingInstructionConvert, TargetCurrency: domain.ZMW}},
+		{"convert with a pocket", CreateRequest{Currency: domain.CNY, Kind: domain.StandingInstructionConvert, TargetCurrency: domain.MWK, PocketID: &pocket.ID}},
+		{"unknown kind", CreateRequest{Currency: domain.CNY, Kind: "auto_invest"}},
+		{"no pocket", CreateRequest{Currency: domain.MWK, Kind: domain.StandingInstructionSweep}},
+		{"unknown pocket", CreateRequest{Currency: domain.MWK, Kind: domain.StandingInstructionSweep, PocketID: new(uuid.UUID)}},
+		{"pocket of another wallet", CreateRequest{Currency: domain.CNY, Kind: domain.StandingInstructionSweep, PocketID: &pocket.ID}},
+		{"someone else's pocket", CreateRequest{Currency: domain.CNY, Kind: domain.StandingInstructionSweep, PocketID: &other.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			s, m := newService(&now)
+			m.wallets.On("FindByUserAndCurrency", ctx, userID, domain.CNY).Return(cny, nil).Maybe()
+			m.wallets.On("FindByUserAndCurrency", ctx, userID, domain.MWK).Return(mwk, nil).Maybe()
+			m.wallets.On("FindByUserAndCurrency", ctx, userID, domain.ZMW).Return(nil, nil).Maybe()
+			m.repo.On("GetPocket", ctx, pocket.ID).Return(pocket, nil).Maybe()
+			m.repo.On("GetPocket", ctx, other.ID).Return(other, nil).Maybe()
+			m.repo.On("GetPocket", ctx, uuid.Nil).Return(nil, ErrPocketNotFound).Maybe()
+
+			_, err := s.Create(ctx, userID, &tt.req)
+			assert.ErrorIs(t, err, ErrInvalidInstruction)
+			m.repo.AssertNotCalled(t, "CreateInstruction", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	userID, cny, mwk := wallets()
+	now := start
+	s, m := newService(&now)
+	m.wallets.On("FindByUserAndCurrency", ctx, userID, domain.CNY).Return(cny, nil).Once()
+	m.wallets.On("FindByUserAndCurrency", ctx, userID, domain.MWK).Return(mwk, nil).Once()
+	m.repo.On("CreateInstruction", ctx, mock.MatchedBy(func(in *domain.StandingInstruction) bool {
+		return in.WalletID == cny.ID && *in.TargetCurrency == domain.MWK && in.Active && in.ActiveSince.Equal(now) &&
+			in.Threshold.String() == "50.01"
+	})).Return(nil).Once()
+
+	_, err := s.Create(ctx, userID, &CreateRequest{
+		Currency: domain.CNY, Kind: domain.StandingInstructionConvert, TargetCurrency: domain.MWK,
+		Threshold: decimal.RequireFromString("50.009"),
+	})
+	require.NoError(t, err)
+	m.assert(t)
+}
+
+func TestInstructionsBelongToTheirOwner(t *testing.T) {
+	_, cny, _ := wallets()
+	in := convertInstruction(cny, 0)
+	now := start
+	s, m := newService(&now)
+	m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil)
+	threshold := decimal.NewFromInt(100)
+
+	_, err := s.Update(ctx, uuid.New(), in.ID, &UpdateRequest{Threshold: &threshold})
+	assert.ErrorIs(t, err, ErrInstructionNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, uuid.New(), in.ID), ErrInstructionNotFound)
+	_, _, err = s.Executions(ctx, uuid.New(), in.ID, 10, 0)
+	assert.ErrorIs(t, err, ErrInstructionNotFound)
+	m.repo.AssertNotCalled(t, "UpdateInstruction", mock.Anything, mock.Anything)
+	m.repo.AssertNotCalled(t, "DeleteInstruction", mock.Anything, mock.Anything)
+}
+
+func TestUpdateReactivatesFromNow(t *testing.T) {
+	_, cny, _ := wallets()
+	in := convertInstruction(cny, 0)
+	in.Active = false
+	now := start.Add(time.Hour)
+	s, m := newService(&now)
+	on := true
+	m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+	// Credits that came in while it was off are left alone.
+	m.repo.On("UpdateInstruction", ctx, mock.MatchedBy(func(got *domain.StandingInstruction) bool {
+		return got.Active && got.ActiveSince.Equal(now)
+	})).Return(nil).Once()
+
+	_, err := s.Update(ctx, in.UserID, in.ID, &UpdateRequest{Active: &on})
+	require.NoError(t, err)
+	m.assert(t)
+}
+
+func TestRunConvertsIncomingCredits(t *testing.T) {
+	_, cny, _ := wallets()
+	cny.AvailableBalance = decimal.NewFromInt(1220)
+	in := convertInstruction(cny, 50)
+	big, small := uuid.New(), uuid.New()
+	now := start.Add(time.Minute)
+	s, m := newService(&now)
+	m.repo.On("PendingCredits", ctx, now.Add(-claimTimeout), 100).Return([]domain.IncomingCredit{
+		{InstructionID: in.ID, TransactionID: big, Amount: decimal.NewFromInt(200)},
+		{InstructionID: in.ID, TransactionID: small, Amount: decimal.NewFromInt(20)},
+	}, nil).Once()
+	// Loaded once for both credits.
+	m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+	m.repo.On("ClaimCredit", ctx, mock.Anything, now.Add(-claimTimeout)).Return(true, nil, nil).Twice()
+	m.wallets.On("FindByID", ctx, cny.ID).Return(cny, nil).Once()
+	m.payments.On("FeeRate", ctx, in.UserID).Return(feeRate, nil).Once()
+	txID := uuid.New()
+	var reference string
+	m.payments.On("Convert", ctx, mock.MatchedBy(func(c *Conversion) bool {
+		reference = c.Reference
+		// The rest of the balance pays the fee
+		return c.UserID == in.UserID && c.From == domain.CNY && c.To == domain.MWK && c.Amount.String() == "200" &&
+			c.Metadata["trigger_transaction_id"] == big.String()
+	})).Return(&domain.Transaction{ID: txID}, nil).Once()
+	m.repo.On("FinishExecution", ctx, mock.MatchedBy(func(e *domain.StandingInstructionExecution) bool {
+		return e.TriggerTransactionID == big && e.Status == domain.StandingExecutionExecuted &&
+			*e.TransactionID == txID && reference == "SI-"+e.ID.String()
+	})).Return(nil).Once()
+	m.repo.On("FinishExecution", ctx, mock.MatchedBy(func(e *domain.StandingInstructionExecution) bool {
+		return e.TriggerTransactionID == small && e.Status == domain.StandingExecutionSkipped
+	})).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &domain.StandingInstructionRun{Examined: 2, Executed: 1, Skipped: 1}, run)
+	m.assert(t)
+}
+
+func TestConvertLeavesTheFeeWhenTheCreditIsAllThereIs(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int64
+		status  domain.StandingExecutionStatus
+		amount  string
+	}{
+		// 98.52 plus its 1.5% fee fits in the 100 received
+		{"credit pays the fee", 100, domain.StandingExecutionExecuted, "98.52"},
+		{"money already spent", 0, domain.StandingExecutionSkipped, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cny, _ := wallets()
+			cny.AvailableBalance = decimal.NewFromInt(tt.balance)
+			in := convertInstruction(cny, 0)
+			now := start
+			s, m := newService(&now)
+			m.repo.On("PendingCredits", ctx, mock.Anything, 100).Return([]domain.IncomingCredit{
+				{InstructionID: in.ID, TransactionID: uuid.New(), Amount: decimal.NewFromInt(100)},
+			}, nil).Once()
+			m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+			m.repo.On("ClaimCredit", ctx, mock.Anything, mock.Anything).Return(true, nil, nil).Once()
+			m.wallets.On("FindByID", ctx, cny.ID).Return(cny, nil).Once()
+			m.payments.On("FeeRate", ctx, in.UserID).Return(feeRate, nil).Once()
+			if tt.status == domain.StandingExecutionExecuted {
+				m.payments.On("Convert", ctx, mock.MatchedBy(func(c *Conversion) bool {
+					return c.Amount.String() == tt.amount
+				})).Return(&domain.Transaction{ID: uuid.New()}, nil).Once()
+			}
+			m.repo.On("FinishExecution", ctx, execution(tt.status, tt.amount)).Return(nil).Once()
+
+			_, err := s.Run(ctx)
+			require.NoError(t, err)
+			m.assert(t)
+		})
+	}
+}
+
+func TestRunRecordsFailedConversions(t *testing.T) {
+	_, cny, _ := wallets()
+	cny.AvailableBalance = decimal.NewFromInt(500)
+	in := convertInstruction(cny, 0)
+	now := start
+	s, m := newService(&now)
+	m.repo.On("PendingCredits", ctx, mock.Anything, 100).Return([]domain.IncomingCredit{
+		{InstructionID: in.ID, TransactionID: uuid.New(), Amount: decimal.NewFromInt(100)},
+	}, nil).Once()
+	m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+	m.repo.On("ClaimCredit", ctx, mock.Anything, mock.Anything).Return(true, nil, nil).Once()
+	m.wallets.On("FindByID", ctx, cny.ID).Return(cny, nil).Once()
+	m.payments.On("FeeRate", ctx, in.UserID).Return(feeRate, nil).Once()
+	m.payments.On("Convert", ctx, mock.Anything).Return(nil, errors.New("identity is not verified")).Once()
+	// A failure is final, not retried on every run
+	m.repo.On("FinishExecution", ctx, mock.MatchedBy(func(e *domain.StandingInstructionExecution) bool {
+		return e.Status == domain.StandingExecutionFailed && e.Reason == "identity is not verified"
+	})).Return(nil).Once()
+	m.notifier.On("Notify", ctx, in.UserID, EventInstructionFailed, map[string]interface{}{
+		"currency": domain.CNY, "reason": "identity is not verified",
+	}).Return(nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &domain.StandingInstructionRun{Examined: 1, Failed: 1}, run)
+	m.assert(t)
+}
+
+func TestRunSweepsAboveTheThreshold(t *testing.T) {
+	userID, _, mwk := wallets()
+	pocket := &domain.SavingsPocket{ID: uuid.New(), UserID: userID, WalletID: mwk.ID, Currency: domain.MWK, Name: "Rainy day"}
+	in := &domain.StandingInstruction{
+		ID: uuid.New(), UserID: userID, WalletID: mwk.ID, Currency: domain.MWK, Kind: domain.StandingInstructionSweep,
+		PocketID: &pocket.ID, Threshold: decimal.NewFromInt(300), Active: true, ActiveSince: start,
+	}
+	credit := []domain.IncomingCredit{{InstructionID: in.ID, TransactionID: uuid.New(), Amount: decimal.NewFromInt(500)}}
+
+	t.Run("swept", func(t *testing.T) {
+		now := start
+		s, m := newService(&now)
+		m.repo.On("PendingCredits", ctx, mock.Anything, 100).Return(credit, nil).Once()
+		m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+		m.repo.On("ClaimCredit", ctx, mock.Anything, mock.Anything).Return(true, nil, nil).Once()
+		swept := *pocket
+		swept.Balance = decimal.NewFromInt(400)
+		m.repo.On("SweepToPocket", ctx, pocket.ID, amount("300")).Return(&swept, decimal.NewFromInt(400), nil).Once()
+		m.repo.On("FinishExecution", ctx, execution(domain.StandingExecutionExecuted, "400")).Return(nil).Once()
+		m.notifier.On("Notify", ctx, userID, EventFundsSwept, map[string]interface{}{
+			"amount": "400", "currency": domain.MWK, "pocket": "Rainy day", "balance": "400",
+		}).Return(nil).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Executed)
+		m.assert(t)
+	})
+
+	t.Run("still below the threshold", func(t *testing.T) {
+		now := start
+		s, m := newService(&now)
+		m.repo.On("PendingCredits", ctx, mock.Anything, 100).Return(credit, nil).Once()
+		m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+		m.repo.On("ClaimCredit", ctx, mock.Anything, mock.Anything).Return(true, nil, nil).Once()
+		m.repo.On("SweepToPocket", ctx, pocket.ID, amount("300")).Return(pocket, decimal.Zero, nil).Once()
+		m.repo.On("FinishExecution", ctx, execution(domain.StandingExecutionSkipped, "0")).Return(nil).Once()
+
+		run, err := s.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, run.Skipped)
+		m.assert(t)
+	})
+}
+
+func TestRunSkipsCreditsClaimedElsewhere(t *testing.T) {
+	_, cny, _ := wallets()
+	in := convertInstruction(cny, 0)
+	now := start
+	s, m := newService(&now)
+	m.repo.On("PendingCredits", ctx, mock.Anything, 100).Return([]domain.IncomingCredit{
+		{InstructionID: in.ID, TransactionID: uuid.New(), Amount: decimal.NewFromInt(100)},
+	}, nil).Once()
+	m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+	m.repo.On("ClaimCredit", ctx, mock.Anything, mock.Anything).Return(false, nil, nil).Once()
+
+	run, err := s.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &domain.StandingInstructionRun{Examined: 1}, run)
+	m.assert(t)
+	m.payments.AssertNotCalled(t, "Convert", mock.Anything, mock.Anything)
+}
+
+func TestRunTakesOverStaleClaims(t *testing.T) {
+	_, cny, _ := wallets()
+	cny.AvailableBalance = decimal.NewFromInt(500)
+	in := convertInstruction(cny, 0)
+	abandoned := uuid.New()
+	now := start
+	s, m := newService(&now)
+	m.repo.On("PendingCredits", ctx, mock.Anything, 100).Return([]domain.IncomingCredit{
+		{InstructionID: in.ID, TransactionID: uuid.New(), Amount: decimal.NewFromInt(100)},
+	}, nil).Once()
+	m.repo.On("GetInstruction", ctx, in.ID).Return(in, nil).Once()
+	m.repo.On("ClaimCredit", ctx, mock.Anything, mock.Anything).Return(true, nil, abandoned).Once()
+	m.wallets.On("FindByID", ctx, cny.ID).Return(cny, nil).Once()
+	m.payments.On("FeeRate", ctx, in.UserID).Return(feeRate, nil).Once()
+	// The retry reuses the claim's reference
+	m.payments.On("Convert", ctx, mock.MatchedBy(func(c *Conversion) bool {
+		return c.Reference == "SI-"+abandoned.String()
+	})).Return(&domain.Transaction{ID: uuid.New()}, nil).Once()
+	m.repo.On("FinishExecution", ctx, mock.MatchedBy(func(e *domain.StandingInstructionExecution) bool {
+		return e.ID == abandoned
+	})).Return(nil).Once()
+
+	_, err := s.Run(ctx)
+	require.NoError(t, err)
+	m.assert(t)
+}
+
+func TestWithdrawFromPocket(t *testing.T) {
+	userID, _, mwk := wallets()
+	pocket := &domain.SavingsPocket{ID: uuid.New(), UserID: userID, WalletID: mwk.ID, Balance: decimal.NewFromInt(250)}
+	now := start
+	s, m := newService(&now)
+	m.repo.On("GetPocket", ctx, pocket.ID).Return(pocket, nil)
+	m.repo.On("WithdrawFromPocket", ctx, pocket.ID, amount("150.01")).Return(pocket, nil).Once()
+	m.repo.On("WithdrawFromPocket", ctx, pocket.ID, amount("251")).Return(nil, ErrPocketBalance).Once()
+
+	_, err := s.WithdrawFromPocket(ctx, userID, pocket.ID, decimal.RequireFromString("150.011"))
+	require.NoError(t, err)
+	_, err = s.WithdrawFromPocket(ctx, userID, pocket.ID, decimal.NewFromInt(251))
+	assert.ErrorIs(t, err, ErrPocketBalance)
+	_, err = s.WithdrawFromPocket(ctx, userID, pocket.ID, decimal.Zero)
+	assert.ErrorIs(t, err, ErrInvalidPocket)
+	_, err = s.WithdrawFromPocket(ctx, uuid.New(), pocket.ID, decimal.NewFromInt(1))
+	assert.ErrorIs(t, err, ErrPocketNotFound)
+	m.assert(t)
+}
+
+func TestCreatePocket(t *testing.T) {
+	userID, _, mwk := wallets()
+	now := start
+	s, m := newService(&now)
+	m.wallets.On("FindByUserAndCurrency", ctx, userID, domain.MWK).Return(mwk, nil).Twice()
+	m.repo.On("CreatePocket", ctx, mock.MatchedBy(func(p *domain.SavingsPocket) bool {
+		return p.Name == "Savings" && p.WalletID == mwk.ID && p.Balance.IsZero()
+	})).Return(nil).Once()
+	m.repo.On("CreatePocket", ctx, mock.Anything).Return(ErrPocketExists).Once()
+
+	p, err := s.CreatePocket(ctx, userID, &CreatePocketRequest{Currency: domain.MWK, Name: " Savings "})
+	require.NoError(t, err)
+	assert.Equal(t, "Savings", p.Name)
+	_, err = s.CreatePocket(ctx, userID, &CreatePocketRequest{Currency: domain.MWK, Name: "Savings"})
+	assert.ErrorIs(t, err, ErrPocketExists)
+	_, err = s.CreatePocket(ctx, userID, &CreatePocketRequest{Currency: domain.MWK, Name: "  "})
+	assert.ErrorIs(t, err, ErrInvalidPocket)
+	m.assert(t)
+}
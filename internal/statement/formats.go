@@ -0,0 +1,222 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"time"
+	"unicode/utf8"
+
+	"kyd/internal/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// camt.053.001.02, limited to the elements ERPs need to reconcile a wallet:
+// the account, its opening and closing booked balances and each booked
+// entry with our reference and the payment's own.
+type camtDocument struct {
+	XMLName xml.Name      `xml:"Document"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Stmt    camtStatement `xml:"BkToCstmrStmt"`
+}
+
+type camtStatement struct {
+	GrpHdr camtGroupHeader `xml:"GrpHdr"`
+	Stmt   camtStmt        `xml:"Stmt"`
+}
+
+type camtGroupHeader struct {
+	MsgID   string `xml:"MsgId"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type camtStmt struct {
+	ID        string        `xml:"Id"`
+	CreDtTm   string        `xml:"CreDtTm"`
+	FrToDt    camtFromTo    `xml:"FrToDt"`
+	Acct      camtAccount   `xml:"Acct"`
+	Bal       []camtBalance `xml:"Bal"`
+	TxsSummry camtSummary   `xml:"TxsSummry"`
+	Ntry      []camtEntry   `xml:"Ntry"`
+}
+
+type camtFromTo struct {
+	FrDtTm string `xml:"FrDtTm"`
+	ToDtTm string `xml:"ToDtTm"`
+}
+
+type camtAccount struct {
+	ID   string     `xml:"Id>Othr>Id"`
+	Ccy  string     `xml:"Ccy"`
+	Ownr *camtOwner `xml:"Ownr,omitempty"`
+}
+
+type camtOwner struct {
+	Nm string `xml:"Nm"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	Dt        string     `xml:"Dt>Dt"`
+}
+
+type camtSummary struct {
+	NbOfNtries int    `xml:"TtlNtries>NbOfNtries"`
+	Sum        string `xml:"TtlNtries>Sum"`
+	CdtCount   int    `xml:"TtlCdtNtries>NbOfNtries"`
+	CdtSum     string `xml:"TtlCdtNtries>Sum"`
+	DbtCount   int    `xml:"TtlDbtNtries>NbOfNtries"`
+	DbtSum     string `xml:"TtlDbtNtries>Sum"`
+}
+
+type camtEntry struct {
+	NtryRef     string     `xml:"NtryRef"`
+	Amt         camtAmount `xml:"Amt"`
+	CdtDbtInd   string     `xml:"CdtDbtInd"`
+	Sts         string     `xml:"Sts"`
+	BookgDt     string     `xml:"BookgDt>DtTm"`
+	ValDt       string     `xml:"ValDt>DtTm"`
+	AcctSvcrRef string     `xml:"AcctSvcrRef"`
+	BkTxCd      string     `xml:"BkTxCd>Prtry>Cd"`
+	EndToEndID  string     `xml:"NtryDtls>TxDtls>Refs>EndToEndId"`
+	Ustrd       string     `xml:"NtryDtls>TxDtls>RmtInf>Ustrd,omitempty"`
+}
+
+const (
+	camtDateTime = "2006-01-02T15:04:05Z"
+	camtDate     = "2006-01-02"
+)
+
+// RenderCAMT053 lays st out as an ISO 20022 camt.053.001.02 statement.
+func RenderCAMT053(st *domain.Statement) ([]byte, error) {
+	ccy := string(st.Currency)
+	doc := camtDocument{
+		Xmlns: camt053Namespace,
+		Stmt: camtStatement{
+			GrpHdr: camtGroupHeader{MsgID: st.ID.String(), CreDtTm: st.CreatedAt.UTC().Format(camtDateTime)},
+			Stmt: camtStmt{
+				ID:      st.ID.String(),
+				CreDtTm: st.CreatedAt.UTC().Format(camtDateTime),
+				FrToDt:  camtFromTo{FrDtTm: st.From.UTC().Format(camtDateTime), ToDtTm: st.To.UTC().Format(camtDateTime)},
+				Acct:    camtAccount{ID: st.WalletNumber, Ccy: ccy},
+				Bal: []camtBalance{
+					camtBal("OPBD", st.OpeningBalance, ccy, st.From),
+					camtBal("CLBD", st.ClosingBalance, ccy, st.To.AddDate(0, 0, -1)),
+				},
+			},
+		},
+	}
+	if st.OwnerName != "" {
+		doc.Stmt.Stmt.Acct.Ownr = &camtOwner{Nm: truncate(st.OwnerName, 140)}
+	}
+	var credits, debits decimal.Decimal
+	sum := &doc.Stmt.Stmt.TxsSummry
+	for _, e := range st.Entries {
+		ind := "CRDT"
+		if e.EntryType == "debit" {
+			ind = "DBIT"
+			debits = debits.Add(e.Amount)
+			sum.DbtCount++
+		} else {
+			credits = credits.Add(e.Amount)
+			sum.CdtCount++
+		}
+		ref := truncate(e.Reference, 35)
+		if ref == "" {
+			ref = "NOTPROVIDED"
+		}
+		booked := e.BookedAt.UTC().Format(camtDateTime)
+		doc.Stmt.Stmt.Ntry = append(doc.Stmt.Stmt.Ntry, camtEntry{
+			NtryRef:     e.ID.String(),
+			Amt:         camtAmount{Ccy: ccy, Value: e.Amount.StringFixed(2)},
+			CdtDbtInd:   ind,
+			Sts:         "BOOK",
+			BookgDt:     booked,
+			ValDt:       booked,
+			AcctSvcrRef: e.TransactionID.String(),
+			BkTxCd:      "TRANSFER",
+			EndToEndID:  ref,
+			Ustrd:       truncate(e.Description, 140),
+		})
+	}
+	sum.NbOfNtries = len(st.Entries)
+	sum.Sum = credits.Add(debits).StringFixed(2)
+	sum.CdtSum = credits.StringFixed(2)
+	sum.DbtSum = debits.StringFixed(2)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// camtBal is a booked balance on day. Balances below zero, on wallets with
+// an overdraft, are debit balances.
+func camtBal(code string, amount decimal.Decimal, ccy string, day time.Time) camtBalance {
+	ind := "CRDT"
+	if amount.IsNegative() {
+		ind = "DBIT"
+	}
+	return camtBalance{
+		Code:      code,
+		Amt:       camtAmount{Ccy: ccy, Value: amount.Abs().StringFixed(2)},
+		CdtDbtInd: ind,
+		Dt:        day.Format(camtDate),
+	}
+}
+
+// csvHeader is the layout of CSV statements: the opening balance, one line
+// per entry and the closing balance, told apart by type.
+var csvHeader = []string{
+	"type", "booked_at", "entry_id", "transaction_id", "reference", "description",
+	"debit", "credit", "balance", "currency",
+}
+
+// RenderCSV lays st out as CSV.
+func RenderCSV(st *domain.Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	ccy := string(st.Currency)
+	rows := [][]string{
+		csvHeader,
+		{"opening_balance", st.From.UTC().Format(camtDateTime), "", "", "", "", "", "", st.OpeningBalance.StringFixed(2), ccy},
+	}
+	for _, e := range st.Entries {
+		debit, credit := "", e.Amount.StringFixed(2)
+		if e.EntryType == "debit" {
+			debit, credit = credit, ""
+		}
+		rows = append(rows, []string{
+			"entry", e.BookedAt.UTC().Format(camtDateTime), e.ID.String(), e.TransactionID.String(),
+			e.Reference, e.Description, debit, credit, e.BalanceAfter.StringFixed(2), ccy,
+		})
+	}
+	rows = append(rows, []string{"closing_balance", st.To.UTC().Format(camtDateTime), "", "", "", "", "", "", st.ClosingBalance.StringFixed(2), ccy})
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
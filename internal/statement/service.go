@@ -0,0 +1,334 @@
+// Package statement pushes daily statements of corporate wallets to the
+// owner's ERP: camt.053 or CSV files, generated from the ledger each UTC day
+// and delivered by webhook or through the owner's SFTP drop, with each
+// delivery tracked until it lands and regenerated on request.
+package statement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("statement schedule not found")
+	// ErrScheduleExists is returned when the wallet already has a schedule
+	// with the same format and channel.
+	ErrScheduleExists   = errors.New("a statement schedule for this wallet, format and channel already exists")
+	ErrInvalidSchedule  = errors.New("invalid statement schedule")
+	ErrDeliveryNotFound = errors.New("statement delivery not found")
+	ErrInvalidPeriod    = errors.New("invalid statement period")
+	// ErrNotReady is returned for the file of a statement not yet
+	// generated.
+	ErrNotReady = errors.New("statement has not been generated yet")
+)
+
+// Repository stores schedules and deliveries, and reads the ledger
+// statements are generated from.
+type Repository interface {
+	// CreateSchedule returns ErrScheduleExists when the wallet already has
+	// a schedule with the same format and channel.
+	CreateSchedule(ctx context.Context, sch *domain.StatementSchedule) error
+	// GetSchedule returns the schedule, or nil.
+	GetSchedule(ctx context.Context, id uuid.UUID) (*domain.StatementSchedule, error)
+	// ListSchedules returns the owner's schedules, or all of them when
+	// owner is nil.
+	ListSchedules(ctx context.Context, owner *uuid.UUID) ([]domain.StatementSchedule, error)
+	DeleteSchedule(ctx context.Context, id uuid.UUID, owner *uuid.UUID) (bool, error)
+	// ListDueSchedules returns up to limit schedules that have not yet
+	// queued a statement for period.
+	ListDueSchedules(ctx context.Context, period time.Time, limit int) ([]domain.StatementSchedule, error)
+	// QueueScheduledStatement records period as the schedule's last and
+	// creates d in one transaction. It reports false, creating nothing,
+	// when the period was already queued, so each day is sent once however
+	// many workers run.
+	QueueScheduledStatement(ctx context.Context, scheduleID uuid.UUID, period time.Time, d *domain.StatementDelivery) (bool, error)
+
+	CreateDelivery(ctx context.Context, d *domain.StatementDelivery) error
+	// GetDelivery returns the delivery with its file, or nil.
+	GetDelivery(ctx context.Context, id uuid.UUID) (*domain.StatementDelivery, error)
+	// ListDeliveries returns the deliveries matching filter, without their
+	// files, newest first, and how many there are.
+	ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]domain.StatementDelivery, int, error)
+	// ClaimDue returns up to limit pending deliveries due at now, with
+	// their files, and pushes their next attempt back by lease so that
+	// other instances leave them alone while they are being sent.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.StatementDelivery, error)
+	// ListSent returns up to limit deliveries handed to webhooks and not
+	// yet known to have landed, least recently updated first, without their
+	// files.
+	ListSent(ctx context.Context, limit int) ([]domain.StatementDelivery, error)
+	// SaveFile stores the generated file of d.
+	SaveFile(ctx context.Context, d *domain.StatementDelivery) error
+	// UpdateDelivery saves the status of d, leaving its file alone.
+	UpdateDelivery(ctx context.Context, d *domain.StatementDelivery) error
+
+	// StatementEntries returns the wallet's ledger entries booked in
+	// [from, to), in booking order, and its balance before from.
+	StatementEntries(ctx context.Context, walletID uuid.UUID, from, to time.Time) (decimal.Decimal, []domain.StatementEntry, error)
+}
+
+// DeliveryFilter narrows a delivery listing. Zero fields match everything.
+type DeliveryFilter struct {
+	OwnerID    *uuid.UUID
+	ScheduleID *uuid.UUID
+	Status     domain.StatementDeliveryStatus
+	Period     *time.Time
+	Limit      int
+	Offset     int
+}
+
+type Wallets interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error)
+}
+
+type Users interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// Webhooks posts statements to the owner's endpoints and reports how the
+// posts went.
+type Webhooks interface {
+	// PublishStatement queues d for the owner's subscribed endpoints and
+	// returns how many it queued.
+	PublishStatement(ctx context.Context, d *domain.StatementDelivery) (int, error)
+	EventDeliveries(ctx context.Context, eventID uuid.UUID) ([]domain.WebhookDelivery, error)
+}
+
+// Drop is the owner's SFTP or bucket drop, named by the owner's user ID.
+type Drop interface {
+	Write(ctx context.Context, customer, folder, name string, data []byte) error
+}
+
+const (
+	defaultPollInterval = time.Minute
+	defaultMaxAttempts  = 6
+	claimBatchSize      = 50
+	scheduleBatchSize   = 100
+	// claimLease is how long a claimed delivery is left to the worker that
+	// claimed it.
+	claimLease = 5 * time.Minute
+	// maxRegenerateAge is how far back a statement may be regenerated.
+	maxRegenerateAge = 400 * 24 * time.Hour
+)
+
+type Service struct {
+	repo     Repository
+	wallets  Wallets
+	users    Users
+	webhooks Webhooks
+	drop     Drop
+	// dropFolder is the folder of each owner's drop statements go to.
+	dropFolder string
+	cfg        config.StatementConfig
+	logger     logger.Logger
+	now        func() time.Time
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+func NewService(repo Repository, wallets Wallets, users Users, cfg config.StatementConfig, log logger.Logger) *Service {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	return &Service{
+		repo:    repo,
+		wallets: wallets,
+		users:   users,
+		cfg:     cfg,
+		logger:  log,
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+}
+
+// WithWebhooks enables delivery by webhook.
+func (s *Service) WithWebhooks(w Webhooks) *Service {
+	s.webhooks = w
+	return s
+}
+
+// WithDrop enables delivery to the owners' SFTP drops, in folder.
+func (s *Service) WithDrop(d Drop, folder string) *Service {
+	s.drop = d
+	s.dropFolder = folder
+	return s
+}
+
+// ScheduleRequest sets up a daily statement of one of the owner's wallets.
+type ScheduleRequest struct {
+	WalletID uuid.UUID               `json:"wallet_id"`
+	Format   domain.StatementFormat  `json:"format"`
+	Channel  domain.StatementChannel `json:"channel"`
+}
+
+// CreateSchedule sends the wallet's statement every day from now on,
+// starting with yesterday's.
+func (s *Service) CreateSchedule(ctx context.Context, owner uuid.UUID, req ScheduleRequest, createdBy uuid.UUID) (*domain.StatementSchedule, error) {
+	format := domain.StatementFormat(strings.ToLower(strings.TrimSpace(string(req.Format))))
+	if format != domain.StatementFormatCAMT053 && format != domain.StatementFormatCSV {
+		return nil, fmt.Errorf("%w: format must be camt053 or csv", ErrInvalidSchedule)
+	}
+	channel := domain.StatementChannel(strings.ToLower(strings.TrimSpace(string(req.Channel))))
+	switch {
+	case channel == domain.StatementChannelWebhook && s.webhooks != nil:
+	case channel == domain.StatementChannelSFTP && s.drop != nil:
+	case channel == domain.StatementChannelWebhook, channel == domain.StatementChannelSFTP:
+		return nil, fmt.Errorf("%w: %s delivery is not available", ErrInvalidSchedule, channel)
+	default:
+		return nil, fmt.Errorf("%w: channel must be webhook or sftp", ErrInvalidSchedule)
+	}
+	if req.WalletID == uuid.Nil {
+		return nil, fmt.Errorf("%w: wallet_id is required", ErrInvalidSchedule)
+	}
+	w, err := s.wallets.FindByID(ctx, req.WalletID)
+	if err != nil || w == nil || w.UserID != owner {
+		// Someone else's wallet is reported as missing, like one that is.
+		return nil, fmt.Errorf("%w: wallet not found", ErrInvalidSchedule)
+	}
+	sch := &domain.StatementSchedule{
+		ID:        uuid.New(),
+		OwnerID:   owner,
+		WalletID:  w.ID,
+		Format:    format,
+		Channel:   channel,
+		CreatedBy: createdBy,
+		CreatedAt: s.now().UTC(),
+	}
+	if err := s.repo.CreateSchedule(ctx, sch); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// ListSchedules returns owner's schedules, or all of them when owner is nil.
+func (s *Service) ListSchedules(ctx context.Context, owner *uuid.UUID) ([]domain.StatementSchedule, error) {
+	return s.repo.ListSchedules(ctx, owner)
+}
+
+// DeleteSchedule stops a schedule. Its deliveries are kept. owner limits it
+// to that owner's schedules; nil allows any (admin).
+func (s *Service) DeleteSchedule(ctx context.Context, owner *uuid.UUID, id uuid.UUID) error {
+	deleted, err := s.repo.DeleteSchedule(ctx, id, owner)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// Regenerate builds the schedule's statement for period, a past UTC day,
+// afresh from the ledger and delivers it again. The new delivery points at
+// the latest one for the same day, if there was one. owner limits it to
+// that owner's schedules; nil allows any (admin).
+func (s *Service) Regenerate(ctx context.Context, owner *uuid.UUID, scheduleID uuid.UUID, period time.Time, requestedBy uuid.UUID) (*domain.StatementDelivery, error) {
+	sch, err := s.repo.GetSchedule(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if sch == nil || (owner != nil && sch.OwnerID != *owner) {
+		return nil, ErrScheduleNotFound
+	}
+	now := s.now().UTC()
+	day := period.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(period.UTC()) {
+		return nil, fmt.Errorf("%w: date must be a whole UTC day", ErrInvalidPeriod)
+	}
+	if !day.AddDate(0, 0, 1).After(now.Add(-maxRegenerateAge)) {
+		return nil, fmt.Errorf("%w: statements go back at most %d days", ErrInvalidPeriod, int(maxRegenerateAge.Hours()/24))
+	}
+	if day.AddDate(0, 0, 1).After(now) {
+		return nil, fmt.Errorf("%w: the day has not ended yet", ErrInvalidPeriod)
+	}
+	previous, _, err := s.repo.ListDeliveries(ctx, DeliveryFilter{ScheduleID: &sch.ID, Period: &day, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	d := s.newDelivery(sch, day)
+	d.RequestedBy = &requestedBy
+	if len(previous) > 0 {
+		d.RegeneratedFrom = &previous[0].ID
+	}
+	if err := s.repo.CreateDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	s.kick()
+	return d, nil
+}
+
+// GetDelivery returns a delivery with its file. owner limits it to that
+// owner's deliveries; nil allows any (admin).
+func (s *Service) GetDelivery(ctx context.Context, owner *uuid.UUID, id uuid.UUID) (*domain.StatementDelivery, error) {
+	d, err := s.repo.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || (owner != nil && d.OwnerID != *owner) {
+		return nil, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+// File returns the statement file of a delivery.
+func (s *Service) File(ctx context.Context, owner *uuid.UUID, id uuid.UUID) (*domain.StatementDelivery, error) {
+	d, err := s.GetDelivery(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Content == nil {
+		return nil, ErrNotReady
+	}
+	return d, nil
+}
+
+// ListDeliveries returns the deliveries matching filter, newest first, and
+// how many there are.
+func (s *Service) ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]domain.StatementDelivery, int, error) {
+	return s.repo.ListDeliveries(ctx, filter)
+}
+
+// newDelivery is a pending delivery of sch's statement for period, due now.
+func (s *Service) newDelivery(sch *domain.StatementSchedule, period time.Time) *domain.StatementDelivery {
+	now := s.now().UTC()
+	scheduleID := sch.ID
+	return &domain.StatementDelivery{
+		ID:            uuid.New(),
+		ScheduleID:    &scheduleID,
+		OwnerID:       sch.OwnerID,
+		WalletID:      sch.WalletID,
+		Period:        period,
+		Format:        sch.Format,
+		Channel:       sch.Channel,
+		Status:        domain.StatementDeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// ContentType is the media type of a statement file.
+func ContentType(f domain.StatementFormat) string {
+	if f == domain.StatementFormatCSV {
+		return "text/csv"
+	}
+	return "application/xml"
+}
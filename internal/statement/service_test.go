@@ -0,0 +1,469 @@
+package statement
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateSchedule(ctx context.Context, sch *domain.StatementSchedule) error {
+	return m.Called(ctx, sch).Error(0)
+}
+
+func (m *MockRepository) GetSchedule(ctx context.Context, id uuid.UUID) (*domain.StatementSchedule, error) {
+	args := m.Called(ctx, id)
+	sch, _ := args.Get(0).(*domain.StatementSchedule)
+	return sch, args.Error(1)
+}
+
+func (m *MockRepository) ListSchedules(ctx context.Context, owner *uuid.UUID) ([]domain.StatementSchedule, error) {
+	args := m.Called(ctx, owner)
+	schedules, _ := args.Get(0).([]domain.StatementSchedule)
+	return schedules, args.Error(1)
+}
+
+func (m *MockRepository) DeleteSchedule(ctx context.Context, id uuid.UUID, owner *uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id, owner)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ListDueSchedules(ctx context.Context, period time.Time, limit int) ([]domain.StatementSchedule, error) {
+	args := m.Called(ctx, period, limit)
+	schedules, _ := args.Get(0).([]domain.StatementSchedule)
+	return schedules, args.Error(1)
+}
+
+func (m *MockRepository) QueueScheduledStatement(ctx context.Context, scheduleID uuid.UUID, period time.Time, d *domain.StatementDelivery) (bool, error) {
+	args := m.Called(ctx, scheduleID, period, d)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) CreateDelivery(ctx context.Context, d *domain.StatementDelivery) error {
+	return m.Called(ctx, d).Error(0)
+}
+
+func (m *MockRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*domain.StatementDelivery, error) {
+	args := m.Called(ctx, id)
+	d, _ := args.Get(0).(*domain.StatementDelivery)
+	return d, args.Error(1)
+}
+
+func (m *MockRepository) ListDeliveries(ctx context.Context, f DeliveryFilter) ([]domain.StatementDelivery, int, error) {
+	args := m.Called(ctx, f)
+	deliveries, _ := args.Get(0).([]domain.StatementDelivery)
+	return deliveries, args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.StatementDelivery, error) {
+	args := m.Called(ctx, now, lease, limit)
+	deliveries, _ := args.Get(0).([]domain.StatementDelivery)
+	return deliveries, args.Error(1)
+}
+
+func (m *MockRepository) ListSent(ctx context.Context, limit int) ([]domain.StatementDelivery, error) {
+	args := m.Called(ctx, limit)
+	deliveries, _ := args.Get(0).([]domain.StatementDelivery)
+	return deliveries, args.Error(1)
+}
+
+func (m *MockRepository) SaveFile(ctx context.Context, d *domain.StatementDelivery) error {
+	return m.Called(ctx, d).Error(0)
+}
+
+func (m *MockRepository) UpdateDelivery(ctx context.Context, d *domain.StatementDelivery) error {
+	return m.Called(ctx, d).Error(0)
+}
+
+func (m *MockRepository) StatementEntries(ctx context.Context, walletID uuid.UUID, from, to time.Time) (decimal.Decimal, []domain.StatementEntry, error) {
+	args := m.Called(ctx, walletID, from, to)
+	entries, _ := args.Get(1).([]domain.StatementEntry)
+	return args.Get(0).(decimal.Decimal), entries, args.Error(2)
+}
+
+type MockWallets struct {
+	mock.Mock
+}
+
+func (m *MockWallets) FindByID(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	args := m.Called(ctx, id)
+	w, _ := args.Get(0).(*domain.Wallet)
+	return w, args.Error(1)
+}
+
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	u, _ := args.Get(0).(*domain.User)
+	return u, args.Error(1)
+}
+
+type MockWebhooks struct {
+	mock.Mock
+}
+
+func (m *MockWebhooks) PublishStatement(ctx context.Context, d *domain.StatementDelivery) (int, error) {
+	args := m.Called(ctx, d)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWebhooks) EventDeliveries(ctx context.Context, eventID uuid.UUID) ([]domain.WebhookDelivery, error) {
+	args := m.Called(ctx, eventID)
+	deliveries, _ := args.Get(0).([]domain.WebhookDelivery)
+	return deliveries, args.Error(1)
+}
+
+type MockDrop struct {
+	mock.Mock
+}
+
+func (m *MockDrop) Write(ctx context.Context, customer, folder, name string, data []byte) error {
+	return m.Called(ctx, customer, folder, name, data).Error(0)
+}
+
+type mocks struct {
+	repo     *MockRepository
+	wallets  *MockWallets
+	users    *MockUsers
+	webhooks *MockWebhooks
+	drop     *MockDrop
+}
+
+func (m *mocks) assert(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.wallets.AssertExpectations(t)
+	m.users.AssertExpectations(t)
+	m.webhooks.AssertExpectations(t)
+	m.drop.AssertExpectations(t)
+}
+
+var (
+	ctx = context.Background()
+	// day is the statement period; its statement is due early the next day.
+	day    = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	owner  = uuid.New()
+	number = "MW1234567890"
+	wallet = &domain.Wallet{ID: uuid.New(), UserID: owner, WalletAddress: &number, Currency: domain.MWK}
+)
+
+func newService(now *time.Time) (*Service, *mocks) {
+	m := &mocks{repo: new(MockRepository), wallets: new(MockWallets), users: new(MockUsers), webhooks: new(MockWebhooks), drop: new(MockDrop)}
+	s := NewService(m.repo, m.wallets, m.users, config.StatementConfig{Delay: time.Hour, MaxAttempts: 3}, logger.NewNop()).
+		WithWebhooks(m.webhooks).
+		WithDrop(m.drop, "outbound")
+	s.now = func() time.Time { return *now }
+	return s, m
+}
+
+// ledger is the wallet's balance before day and its entries on day.
+func ledger() (decimal.Decimal, []domain.StatementEntry) {
+	return decimal.NewFromInt(5000), []domain.StatementEntry{
+		{ID: uuid.New(), TransactionID: uuid.New(), EntryType: "credit", Amount: decimal.NewFromInt(12000), BalanceAfter: decimal.NewFromInt(17000), Reference: "INV-1001", Description: "Invoice 1001", BookedAt: day.Add(9 * time.Hour)},
+		{ID: uuid.New(), TransactionID: uuid.New(), EntryType: "debit", Amount: decimal.RequireFromString("2500.50"), BalanceAfter: decimal.RequireFromString("14499.50"), Reference: "PAY-77", Description: "Supplier, \"Lilongwe\"", BookedAt: day.Add(15 * time.Hour)},
+	}
+}
+
+// pending is the wallet's statement for day, queued at now and not yet
+// generated.
+func pending(format domain.StatementFormat, channel domain.StatementChannel, now time.Time) domain.StatementDelivery {
+	scheduleID := uuid.New()
+	return domain.StatementDelivery{
+		ID: uuid.New(), ScheduleID: &scheduleID, OwnerID: owner, WalletID: wallet.ID, Period: day,
+		Format: format, Channel: channel, Status: domain.StatementDeliveryPending,
+		NextAttemptAt: &now, CreatedAt: now, UpdatedAt: now,
+	}
+}
+
+// generates has the next statement built from ledger(), handing back the
+// file saved.
+func generates(m *mocks, file *[]byte) {
+	name := "Chambo Traders Ltd"
+	opening, entries := ledger()
+	m.wallets.On("FindByID", ctx, wallet.ID).Return(wallet, nil).Once()
+	m.users.On("FindByID", ctx, owner).Return(&domain.User{ID: owner, UserType: domain.UserTypeMerchant, BusinessName: &name}, nil).Once()
+	m.repo.On("StatementEntries", ctx, wallet.ID, day, day.AddDate(0, 0, 1)).Return(opening, entries, nil).Once()
+	m.repo.On("SaveFile", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.EntryCount == 2 && d.FileSize == int64(len(d.Content)) && len(d.SHA256) == 64
+	})).Run(func(args mock.Arguments) { *file = args.Get(1).(*domain.StatementDelivery).Content }).Return(nil).Once()
+}
+
+func TestCreateSchedule(t *testing.T) {
+	now := day.Add(26 * time.Hour)
+	s, m := newService(&now)
+	m.wallets.On("FindByID", ctx, wallet.ID).Return(wallet, nil)
+	ok := ScheduleRequest{WalletID: wallet.ID, Format: "CAMT053", Channel: "webhook"}
+
+	m.repo.On("CreateSchedule", ctx, mock.MatchedBy(func(sch *domain.StatementSchedule) bool {
+		return sch.OwnerID == owner && sch.WalletID == wallet.ID && sch.Channel == domain.StatementChannelWebhook &&
+			sch.LastPeriod == nil && sch.CreatedAt.Equal(now)
+	})).Return(nil).Once()
+	sch, err := s.CreateSchedule(ctx, owner, ok, owner)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatementFormatCAMT053, sch.Format)
+	m.repo.On("CreateSchedule", ctx, mock.Anything).Return(ErrScheduleExists).Once()
+	_, err = s.CreateSchedule(ctx, owner, ok, owner)
+	assert.ErrorIs(t, err, ErrScheduleExists)
+	m.assert(t)
+
+	bad := []ScheduleRequest{
+		{WalletID: wallet.ID, Format: "pdf", Channel: "webhook"},
+		{WalletID: wallet.ID, Format: "csv", Channel: "email"},
+		{Format: "csv", Channel: "sftp"},
+	}
+	for _, req := range bad {
+		_, err := s.CreateSchedule(ctx, owner, req, owner)
+		assert.ErrorIs(t, err, ErrInvalidSchedule)
+	}
+	// Another owner's wallet is not found
+	_, err = s.CreateSchedule(ctx, uuid.New(), ScheduleRequest{WalletID: wallet.ID, Format: "csv", Channel: "sftp"}, owner)
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+	// Nor is a channel not set up
+	s.drop = nil
+	_, err = s.CreateSchedule(ctx, owner, ScheduleRequest{WalletID: wallet.ID, Format: "csv", Channel: "sftp"}, owner)
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+	m.repo.AssertNumberOfCalls(t, "CreateSchedule", 2)
+}
+
+func TestQueueScheduled(t *testing.T) {
+	now := day.Add(26 * time.Hour)
+	s, m := newService(&now)
+
+	// Yesterday's statement is due once the delay has passed
+	assert.Equal(t, day.AddDate(0, 0, -1), s.statementPeriod(day.Add(24*time.Hour+30*time.Minute)))
+	assert.Equal(t, day, s.statementPeriod(day.Add(25*time.Hour)))
+
+	fresh := domain.StatementSchedule{ID: uuid.New(), OwnerID: owner, WalletID: wallet.ID, Format: domain.StatementFormatCSV, Channel: domain.StatementChannelSFTP}
+	taken := fresh
+	taken.ID = uuid.New()
+	broken := fresh
+	broken.ID = uuid.New()
+	m.repo.On("ListDueSchedules", ctx, day, scheduleBatchSize).Return([]domain.StatementSchedule{fresh, taken, broken}, nil).Once()
+	m.repo.On("QueueScheduledStatement", ctx, fresh.ID, day, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return *d.ScheduleID == fresh.ID && d.WalletID == wallet.ID && d.Period.Equal(day) && d.Channel == domain.StatementChannelSFTP &&
+			d.Status == domain.StatementDeliveryPending && d.NextAttemptAt.Equal(now)
+	})).Return(true, nil).Once()
+	// Queued by another worker first
+	m.repo.On("QueueScheduledStatement", ctx, taken.ID, day, mock.Anything).Return(false, nil).Once()
+	m.repo.On("QueueScheduledStatement", ctx, broken.ID, day, mock.Anything).Return(false, errors.New("database unavailable")).Once()
+
+	assert.Equal(t, 1, s.QueueScheduled(ctx))
+	m.assert(t)
+}
+
+func TestScheduledStatementDroppedBySFTP(t *testing.T) {
+	now := day.Add(26 * time.Hour)
+	s, m := newService(&now)
+	d := pending(domain.StatementFormatCSV, domain.StatementChannelSFTP, now)
+	m.repo.On("ClaimDue", ctx, now, claimLease, claimBatchSize).Return([]domain.StatementDelivery{d}, nil).Once()
+	var file []byte
+	generates(m, &file)
+	name := "statement-MW1234567890-2026-03-01.csv"
+	m.drop.On("Write", ctx, owner.String(), "outbound", name, mock.Anything).Return(nil).Once()
+	var saved domain.StatementDelivery
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliveryDelivered && d.Attempts == 1 && d.NextAttemptAt == nil &&
+			d.DeliveredAt != nil && d.DeliveredAt.Equal(now)
+	})).Run(func(args mock.Arguments) { saved = *args.Get(1).(*domain.StatementDelivery) }).Return(nil).Once()
+
+	assert.Equal(t, 1, s.DeliverDue(ctx))
+	m.assert(t)
+	assert.Equal(t, name, saved.FileName)
+	assert.Equal(t, file, m.drop.Calls[0].Arguments.Get(4), "the file saved is the file dropped")
+
+	rows, err := csv.NewReader(strings.NewReader(string(file))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	assert.Equal(t, []string{"opening_balance", "2026-03-01T00:00:00Z", "", "", "", "", "", "", "5000.00", "MWK"}, rows[1])
+	assert.Equal(t, "INV-1001", rows[2][4])
+	assert.Equal(t, "12000.00", rows[2][7])
+	assert.Equal(t, "2500.50", rows[3][6])
+	assert.Equal(t, "Supplier, \"Lilongwe\"", rows[3][5])
+	assert.Equal(t, "14499.50", rows[4][8])
+
+	t.Run("its file", func(t *testing.T) {
+		s, m := newService(&now)
+		m.repo.On("GetDelivery", ctx, saved.ID).Return(&saved, nil)
+		got, err := s.File(ctx, &owner, saved.ID)
+		require.NoError(t, err)
+		assert.Equal(t, saved.SHA256, got.SHA256)
+		stranger := uuid.New()
+		_, err = s.File(ctx, &stranger, saved.ID)
+		assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+		queued := pending(domain.StatementFormatCSV, domain.StatementChannelSFTP, now)
+		m.repo.On("GetDelivery", ctx, queued.ID).Return(&queued, nil).Once()
+		_, err = s.File(ctx, nil, queued.ID)
+		assert.ErrorIs(t, err, ErrNotReady)
+	})
+}
+
+func TestWebhookStatementTrackedUntilDelivered(t *testing.T) {
+	now := day.Add(26 * time.Hour)
+	s, m := newService(&now)
+	d := pending(domain.StatementFormatCAMT053, domain.StatementChannelWebhook, now)
+	m.repo.On("ClaimDue", ctx, now, claimLease, claimBatchSize).Return([]domain.StatementDelivery{d}, nil).Once()
+	var file []byte
+	generates(m, &file)
+	m.webhooks.On("PublishStatement", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.FileName == "statement-MW1234567890-2026-03-01.xml" && len(d.Content) > 0
+	})).Return(1, nil).Once()
+	var sent domain.StatementDelivery
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliverySent && d.SentAt.Equal(now) && d.DeliveredAt == nil
+	})).Run(func(args mock.Arguments) { sent = *args.Get(1).(*domain.StatementDelivery) }).Return(nil).Once()
+	assert.Equal(t, 1, s.DeliverDue(ctx))
+	m.assert(t)
+
+	// Still on its way: nothing changes, but it goes to the back of the list
+	hook := domain.WebhookDelivery{ID: uuid.New(), EventID: d.ID, Status: domain.WebhookDeliveryPending}
+	m.repo.On("ListSent", ctx, claimBatchSize).Return([]domain.StatementDelivery{sent}, nil).Once()
+	m.webhooks.On("EventDeliveries", ctx, d.ID).Return([]domain.WebhookDelivery{hook}, nil).Once()
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliverySent
+	})).Return(nil).Once()
+	assert.Equal(t, 0, s.TrackSent(ctx))
+	m.assert(t)
+
+	at := now.Add(time.Minute)
+	hook.Status, hook.DeliveredAt = domain.WebhookDeliverySucceeded, &at
+	m.repo.On("ListSent", ctx, claimBatchSize).Return([]domain.StatementDelivery{sent}, nil).Once()
+	m.webhooks.On("EventDeliveries", ctx, d.ID).Return([]domain.WebhookDelivery{hook}, nil).Once()
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliveryDelivered && d.DeliveredAt.Equal(at)
+	})).Return(nil).Once()
+	assert.Equal(t, 1, s.TrackSent(ctx))
+	m.assert(t)
+}
+
+func TestWebhookStatementFailsWhenEveryEndpointGivesUp(t *testing.T) {
+	now := day.Add(27 * time.Hour)
+	s, m := newService(&now)
+	sent := pending(domain.StatementFormatCSV, domain.StatementChannelWebhook, now)
+	sent.Status, sent.NextAttemptAt = domain.StatementDeliverySent, nil
+	m.repo.On("ListSent", ctx, claimBatchSize).Return([]domain.StatementDelivery{sent}, nil).Twice()
+	failed := domain.WebhookDelivery{ID: uuid.New(), EventID: sent.ID, Status: domain.WebhookDeliveryFailed, LastError: "endpoint returned 500"}
+	retrying := domain.WebhookDelivery{ID: uuid.New(), EventID: sent.ID, Status: domain.WebhookDeliveryPending}
+
+	m.webhooks.On("EventDeliveries", ctx, sent.ID).Return([]domain.WebhookDelivery{failed, retrying}, nil).Once()
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliverySent
+	})).Return(nil).Once()
+	assert.Equal(t, 0, s.TrackSent(ctx), "the other endpoint may still take it")
+
+	retrying.Status, retrying.LastError = domain.WebhookDeliveryFailed, "endpoint returned 503"
+	m.webhooks.On("EventDeliveries", ctx, sent.ID).Return([]domain.WebhookDelivery{failed, retrying}, nil).Once()
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliveryFailed && strings.Contains(d.LastError, "503")
+	})).Return(nil).Once()
+	assert.Equal(t, 1, s.TrackSent(ctx))
+	m.assert(t)
+}
+
+func TestDeliveryRetriedThenFailed(t *testing.T) {
+	now := day.Add(26 * time.Hour)
+	s, m := newService(&now)
+	d := pending(domain.StatementFormatCSV, domain.StatementChannelWebhook, now)
+	m.repo.On("ClaimDue", ctx, now, claimLease, claimBatchSize).Return([]domain.StatementDelivery{d}, nil).Once()
+	var file []byte
+	generates(m, &file)
+	m.webhooks.On("PublishStatement", ctx, mock.Anything).Return(0, nil)
+	var saved domain.StatementDelivery
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliveryPending && d.Attempts == 1 && d.NextAttemptAt.Equal(now.Add(time.Minute)) &&
+			strings.Contains(d.LastError, "no active webhook endpoint")
+	})).Run(func(args mock.Arguments) { saved = *args.Get(1).(*domain.StatementDelivery) }).Return(nil).Once()
+	assert.Equal(t, 1, s.DeliverDue(ctx))
+	m.assert(t)
+
+	// Retries send the file generated first
+	saved.Content = file
+	now = now.Add(time.Hour)
+	m.repo.On("ClaimDue", ctx, now, claimLease, claimBatchSize).Return([]domain.StatementDelivery{saved}, nil).Once()
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliveryPending && d.Attempts == 2 && d.NextAttemptAt.Equal(now.Add(5*time.Minute))
+	})).Run(func(args mock.Arguments) { saved = *args.Get(1).(*domain.StatementDelivery) }).Return(nil).Once()
+	assert.Equal(t, 1, s.DeliverDue(ctx))
+
+	now = now.Add(time.Hour)
+	m.repo.On("ClaimDue", ctx, now, claimLease, claimBatchSize).Return([]domain.StatementDelivery{saved}, nil).Once()
+	m.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return d.Status == domain.StatementDeliveryFailed && d.Attempts == 3 && d.NextAttemptAt == nil
+	})).Return(nil).Once()
+	assert.Equal(t, 1, s.DeliverDue(ctx))
+	m.assert(t)
+	m.repo.AssertNumberOfCalls(t, "SaveFile", 1)
+}
+
+func TestRegenerate(t *testing.T) {
+	now := day.Add(50 * time.Hour)
+	s, m := newService(&now)
+	sch := &domain.StatementSchedule{ID: uuid.New(), OwnerID: owner, WalletID: wallet.ID, Format: domain.StatementFormatCSV, Channel: domain.StatementChannelSFTP}
+	m.repo.On("GetSchedule", ctx, sch.ID).Return(sch, nil)
+
+	stranger := uuid.New()
+	_, err := s.Regenerate(ctx, &stranger, sch.ID, day, owner)
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+	for _, bad := range []time.Time{day.Add(time.Hour), day.AddDate(0, 0, 2), day.AddDate(-2, 0, 0)} {
+		_, err = s.Regenerate(ctx, &owner, sch.ID, bad, owner)
+		assert.ErrorIs(t, err, ErrInvalidPeriod, bad.String())
+	}
+
+	original := pending(sch.Format, sch.Channel, day.Add(26*time.Hour))
+	m.repo.On("ListDeliveries", ctx, DeliveryFilter{ScheduleID: &sch.ID, Period: &day, Limit: 1}).Return([]domain.StatementDelivery{original}, 1, nil).Once()
+	m.repo.On("CreateDelivery", ctx, mock.MatchedBy(func(d *domain.StatementDelivery) bool {
+		return *d.RegeneratedFrom == original.ID && *d.RequestedBy == owner && d.Period.Equal(day) &&
+			d.Status == domain.StatementDeliveryPending && d.NextAttemptAt.Equal(now)
+	})).Return(nil).Once()
+	d, err := s.Regenerate(ctx, &owner, sch.ID, day, owner)
+	require.NoError(t, err)
+	m.assert(t)
+
+	// The new file does not overwrite the one the ERP may have imported
+	m.repo.On("ClaimDue", ctx, now, claimLease, claimBatchSize).Return([]domain.StatementDelivery{*d}, nil).Once()
+	var file []byte
+	generates(m, &file)
+	m.drop.On("Write", ctx, owner.String(), "outbound", "statement-MW1234567890-2026-03-01-"+d.ID.String()[:8]+".csv", mock.Anything).Return(nil).Once()
+	m.repo.On("UpdateDelivery", ctx, mock.Anything).Return(nil).Once()
+	assert.Equal(t, 1, s.DeliverDue(ctx))
+	m.assert(t)
+}
+
+func TestRenderCAMT053(t *testing.T) {
+	opening, entries := ledger()
+	out, err := RenderCAMT053(&domain.Statement{
+		ID: uuid.New(), WalletID: wallet.ID, WalletNumber: "MW1234567890", OwnerName: "Chambo Traders Ltd",
+		Currency: domain.MWK, From: day, To: day.AddDate(0, 0, 1),
+		OpeningBalance: opening, ClosingBalance: decimal.NewFromInt(-250), Entries: entries, CreatedAt: day.Add(26 * time.Hour),
+	})
+	require.NoError(t, err)
+	xml := string(out)
+	assert.Contains(t, xml, `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">`)
+	assert.Regexp(t, `<Othr>\s*<Id>MW1234567890</Id>`, xml)
+	assert.Contains(t, xml, "<Cd>OPBD</Cd>")
+	assert.Contains(t, xml, `<Amt Ccy="MWK">5000.00</Amt>`)
+	// An overdrawn closing balance is a debit balance
+	assert.Regexp(t, `<Cd>CLBD</Cd>\s*</CdOrPrtry>\s*</Tp>\s*<Amt Ccy="MWK">250.00</Amt>\s*<CdtDbtInd>DBIT</CdtDbtInd>`, xml)
+	assert.Equal(t, 2, strings.Count(xml, "<Ntry>"))
+	assert.Contains(t, xml, "<EndToEndId>INV-1001</EndToEndId>")
+	assert.Contains(t, xml, "<Ustrd>Supplier, &#34;Lilongwe&#34;</Ustrd>")
+	assert.Contains(t, xml, "<NbOfNtries>2</NbOfNtries>")
+}
@@ -0,0 +1,337 @@
+package statement
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+)
+
+var errNoEndpoint = errors.New("no active webhook endpoint is subscribed to statement.ready")
+
+// retrySchedule is the wait before each retry; the last entry repeats.
+var retrySchedule = []time.Duration{
+	time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+}
+
+// Start queues, sends and tracks statements every poll interval, and sends
+// regenerated ones as soon as they are requested, until Stop.
+func (s *Service) Start() {
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(s.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.Run(context.Background())
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+	s.logger.Info("Statement worker started", map[string]interface{}{"interval": s.cfg.PollInterval.String()})
+}
+
+// Stop ends the worker after the round in flight.
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.done.Wait()
+}
+
+// kick wakes the worker without blocking.
+func (s *Service) kick() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run does one round of the worker's work.
+func (s *Service) Run(ctx context.Context) {
+	s.QueueScheduled(ctx)
+	s.DeliverDue(ctx)
+	s.TrackSent(ctx)
+}
+
+// statementPeriod is the UTC day whose scheduled statements are due at now.
+func (s *Service) statementPeriod(now time.Time) time.Time {
+	return now.UTC().Add(-s.cfg.Delay).Truncate(24*time.Hour).AddDate(0, 0, -1)
+}
+
+// QueueScheduled queues the statement of every schedule that has not yet
+// had one for the latest finished day, and returns how many it queued.
+func (s *Service) QueueScheduled(ctx context.Context) int {
+	period := s.statementPeriod(s.now())
+	due, err := s.repo.ListDueSchedules(ctx, period, scheduleBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list due statement schedules", map[string]interface{}{"error": err.Error()})
+		return 0
+	}
+	queued := 0
+	for i := range due {
+		ok, err := s.repo.QueueScheduledStatement(ctx, due[i].ID, period, s.newDelivery(&due[i], period))
+		if err != nil {
+			s.logger.Error("Failed to queue scheduled statement", map[string]interface{}{
+				"error":       err.Error(),
+				"schedule_id": due[i].ID,
+				"period":      period.Format("2006-01-02"),
+			})
+			continue
+		}
+		if ok {
+			queued++
+		}
+	}
+	return queued
+}
+
+// DeliverDue sends the deliveries that are due, one batch at a time, and
+// returns how many it attempted.
+func (s *Service) DeliverDue(ctx context.Context) int {
+	attempted := 0
+	for {
+		batch, err := s.repo.ClaimDue(ctx, s.now().UTC(), claimLease, claimBatchSize)
+		if err != nil {
+			s.logger.Error("Failed to claim statement deliveries", map[string]interface{}{"error": err.Error()})
+			return attempted
+		}
+		for i := range batch {
+			s.deliver(ctx, &batch[i])
+		}
+		attempted += len(batch)
+		if len(batch) < claimBatchSize {
+			return attempted
+		}
+		select {
+		case <-s.stop:
+			return attempted
+		default:
+		}
+	}
+}
+
+// deliver makes one attempt at d, generating its file first if it has
+// none, and records the outcome.
+func (s *Service) deliver(ctx context.Context, d *domain.StatementDelivery) {
+	d.Attempts++
+	err := s.attempt(ctx, d)
+	now := s.now().UTC()
+	d.UpdatedAt = now
+	if err == nil {
+		d.NextAttemptAt = nil
+		d.LastError = ""
+		s.save(ctx, d)
+		return
+	}
+	d.LastError = err.Error()
+	if d.Attempts >= s.cfg.MaxAttempts {
+		d.Status = domain.StatementDeliveryFailed
+		d.NextAttemptAt = nil
+		s.logger.Warn("Statement delivery gave up", map[string]interface{}{
+			"delivery_id": d.ID,
+			"wallet_id":   d.WalletID,
+			"period":      d.Period.Format("2006-01-02"),
+			"attempts":    d.Attempts,
+			"error":       d.LastError,
+		})
+	} else {
+		next := now.Add(retryDelay(d.Attempts))
+		d.NextAttemptAt = &next
+	}
+	s.save(ctx, d)
+}
+
+// attempt generates d's file if needed and sends it down d's channel.
+func (s *Service) attempt(ctx context.Context, d *domain.StatementDelivery) error {
+	if d.Content == nil {
+		if err := s.generate(ctx, d); err != nil {
+			return fmt.Errorf("generate statement: %w", err)
+		}
+	}
+	now := s.now().UTC()
+	switch d.Channel {
+	case domain.StatementChannelSFTP:
+		if s.drop == nil {
+			return errors.New("sftp delivery is not available")
+		}
+		if err := s.drop.Write(ctx, d.OwnerID.String(), s.dropFolder, d.FileName, d.Content); err != nil {
+			return err
+		}
+		d.Status = domain.StatementDeliveryDelivered
+		d.SentAt, d.DeliveredAt = &now, &now
+	case domain.StatementChannelWebhook:
+		if s.webhooks == nil {
+			return errors.New("webhook delivery is not available")
+		}
+		n, err := s.webhooks.PublishStatement(ctx, d)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errNoEndpoint
+		}
+		// Landed once an endpoint accepts it; see TrackSent.
+		d.Status = domain.StatementDeliverySent
+		d.SentAt = &now
+	default:
+		return fmt.Errorf("unknown channel %q", d.Channel)
+	}
+	return nil
+}
+
+// generate builds d's file from the ledger and stores it.
+func (s *Service) generate(ctx context.Context, d *domain.StatementDelivery) error {
+	w, err := s.wallets.FindByID(ctx, d.WalletID)
+	if err != nil {
+		return err
+	}
+	owner, err := s.users.FindByID(ctx, d.OwnerID)
+	if err != nil {
+		return err
+	}
+	from, to := d.Period, d.Period.AddDate(0, 0, 1)
+	opening, entries, err := s.repo.StatementEntries(ctx, d.WalletID, from, to)
+	if err != nil {
+		return err
+	}
+	st := &domain.Statement{
+		ID:             d.ID,
+		WalletID:       w.ID,
+		WalletNumber:   w.ID.String(),
+		Currency:       w.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+		Entries:        entries,
+		CreatedAt:      s.now().UTC(),
+	}
+	if w.WalletAddress != nil && *w.WalletAddress != "" {
+		st.WalletNumber = *w.WalletAddress
+	}
+	if owner != nil {
+		st.OwnerName = ownerName(owner)
+	}
+	if n := len(entries); n > 0 {
+		st.ClosingBalance = entries[n-1].BalanceAfter
+	}
+
+	var content []byte
+	ext := "xml"
+	switch d.Format {
+	case domain.StatementFormatCSV:
+		content, err = RenderCSV(st)
+		ext = "csv"
+	default:
+		content, err = RenderCAMT053(st)
+	}
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	d.Content = content
+	d.FileSize = int64(len(content))
+	d.SHA256 = hex.EncodeToString(sum[:])
+	d.EntryCount = len(entries)
+	d.FileName = fileName(st.WalletNumber, d, ext)
+	return s.repo.SaveFile(ctx, d)
+}
+
+// fileName names a statement file. Regenerated files carry part of their
+// delivery ID, so that they never overwrite a file the ERP may already
+// have imported.
+func fileName(wallet string, d *domain.StatementDelivery, ext string) string {
+	name := "statement-" + wallet + "-" + d.Period.Format("2006-01-02")
+	if d.RegeneratedFrom != nil {
+		name += "-" + d.ID.String()[:8]
+	}
+	return name + "." + ext
+}
+
+func ownerName(u *domain.User) string {
+	if u.BusinessName != nil && strings.TrimSpace(*u.BusinessName) != "" {
+		return strings.TrimSpace(*u.BusinessName)
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// TrackSent settles statements handed to webhooks: delivered once any
+// endpoint accepted one, failed once every endpoint gave up on it. It
+// returns how many it settled.
+func (s *Service) TrackSent(ctx context.Context) int {
+	if s.webhooks == nil {
+		return 0
+	}
+	sent, err := s.repo.ListSent(ctx, claimBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to list sent statements", map[string]interface{}{"error": err.Error()})
+		return 0
+	}
+	settled := 0
+	for i := range sent {
+		d := &sent[i]
+		hooks, err := s.webhooks.EventDeliveries(ctx, d.ID)
+		if err != nil {
+			s.logger.Error("Failed to load statement webhook deliveries", map[string]interface{}{"delivery_id": d.ID, "error": err.Error()})
+			continue
+		}
+		var delivered *time.Time
+		failed, lastError := 0, ""
+		for _, h := range hooks {
+			switch h.Status {
+			case domain.WebhookDeliverySucceeded:
+				if delivered == nil || (h.DeliveredAt != nil && h.DeliveredAt.Before(*delivered)) {
+					delivered = h.DeliveredAt
+					if delivered == nil {
+						at := h.UpdatedAt
+						delivered = &at
+					}
+				}
+			case domain.WebhookDeliveryFailed:
+				failed++
+				lastError = h.LastError
+			}
+		}
+		d.UpdatedAt = s.now().UTC()
+		switch {
+		case delivered != nil:
+			d.Status = domain.StatementDeliveryDelivered
+			d.DeliveredAt = delivered
+			d.LastError = ""
+			settled++
+		case len(hooks) > 0 && failed == len(hooks):
+			d.Status = domain.StatementDeliveryFailed
+			d.LastError = "webhook delivery failed: " + lastError
+			settled++
+		}
+		// Saved either way: one still in flight goes to the back of the
+		// list, so that the next round looks at others.
+		s.save(ctx, d)
+	}
+	return settled
+}
+
+func (s *Service) save(ctx context.Context, d *domain.StatementDelivery) {
+	if err := s.repo.UpdateDelivery(ctx, d); err != nil {
+		s.logger.Error("Failed to record statement delivery", map[string]interface{}{"delivery_id": d.ID, "error": err.Error()})
+	}
+}
+
+// retryDelay is the wait after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > len(retrySchedule) {
+		return retrySchedule[len(retrySchedule)-1]
+	}
+	return retrySchedule[attempts-1]
+}
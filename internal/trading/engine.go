@@ -9,6 +9,8 @@ import (
 	"sync"
 	"time"
 
+	"kyd/pkg/clock"
+
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -428,12 +430,11 @@ func (mm *MarketMaker) CalculateDynamicSpread(pair string, midPrice decimal.Deci
 	spread := mm.config.BaseSpread
 
 	if mm.config.TimeOfDayFactor {
-		hour := time.Now().Hour()
-		if hour < 8 || hour >= 18 {
+		now := clock.BusinessNow()
+		if hour := now.Hour(); hour < 8 || hour >= 18 {
 			spread *= 1.5
 		}
-		weekday := time.Now().Weekday()
-		if weekday == time.Saturday || weekday == time.Sunday {
+		if clock.IsWeekend(now) {
 			spread *= 1.3
 		}
 	}
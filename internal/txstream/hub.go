@@ -0,0 +1,157 @@
+package txstream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// subscriptionBuffer is how many updates a connection may fall behind by
+// before it is dropped.
+const subscriptionBuffer = 32
+
+// subscribeRetry is how long the hub waits before resubscribing after the
+// bus fails.
+const subscribeRetry = 5 * time.Second
+
+// Subscription receives the updates of one customer's transactions. C is
+// closed when the subscriber falls too far behind, or the hub stops; the
+// client should then reconnect and fetch its transactions again.
+type Subscription struct {
+	C      <-chan *Update
+	c      chan *Update
+	userID uuid.UUID
+	closed bool
+}
+
+// Hub hands the updates announced on the bus to the connections on this
+// instance of the customers they concern.
+type Hub struct {
+	bus    Bus
+	logger logger.Logger
+
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[*Subscription]struct{}
+
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewHub(bus Bus, log logger.Logger) *Hub {
+	return &Hub{
+		bus:    bus,
+		logger: log,
+		subs:   make(map[uuid.UUID]map[*Subscription]struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start subscribes to the bus until Stop is called.
+func (h *Hub) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	go func() {
+		defer close(h.done)
+		for ctx.Err() == nil {
+			err := h.bus.Subscribe(ctx, h.Deliver)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				h.logger.Warn("Transaction update subscription failed", map[string]interface{}{"error": err.Error()})
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(subscribeRetry):
+			}
+		}
+	}()
+}
+
+// Stop unsubscribes from the bus and closes every subscription.
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() {
+		if h.cancel != nil {
+			h.cancel()
+			<-h.done
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, subs := range h.subs {
+			for sub := range subs {
+				h.close(sub)
+			}
+		}
+	})
+}
+
+// Subscribe starts receiving the updates of userID's transactions.
+// Unsubscribe must be called when the connection ends.
+func (h *Hub) Subscribe(userID uuid.UUID) *Subscription {
+	c := make(chan *Update, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, userID: userID}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.close(sub)
+}
+
+// Deliver hands u to the subscriptions of the transaction's sender and
+// receiver. A subscription whose buffer is full is closed rather than
+// left to miss updates silently.
+func (h *Hub) Deliver(u *Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	parties := []uuid.UUID{u.Transaction.SenderID}
+	if u.Transaction.ReceiverID != u.Transaction.SenderID {
+		parties = append(parties, u.Transaction.ReceiverID)
+	}
+	for _, userID := range parties {
+		for sub := range h.subs[userID] {
+			select {
+			case sub.c <- u:
+			default:
+				h.logger.Warn("Transaction update subscriber fell behind", map[string]interface{}{"user_id": userID})
+				h.close(sub)
+			}
+		}
+	}
+}
+
+// Connections is how many subscriptions are open on this instance.
+func (h *Hub) Connections() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, subs := range h.subs {
+		n += len(subs)
+	}
+	return n
+}
+
+// close removes sub and closes its channel. h.mu must be held.
+func (h *Hub) close(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.c)
+	delete(h.subs[sub.userID], sub)
+	if len(h.subs[sub.userID]) == 0 {
+		delete(h.subs, sub.userID)
+	}
+}
@@ -0,0 +1,206 @@
+// Package txstream pushes transaction status changes to the customers they
+// concern while they are connected, so apps need not poll for them.
+//
+// The services append lifecycle events through a Stream, which announces
+// each one that customers follow on a Bus shared by every instance. Each
+// payment service instance runs a Hub subscribed to the bus, handing the
+// updates to the connections of the transaction's sender and receiver.
+// Delivery is best effort: a client that reconnects should fetch its
+// transactions again to catch up.
+package txstream
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+)
+
+// Channel is the Redis pub/sub channel updates are announced on.
+const Channel = "transactions:updates"
+
+// UpdateType is the change a customer is told about.
+type UpdateType string
+
+const (
+	UpdateCreated         UpdateType = "transaction.created"
+	UpdatePendingApproval UpdateType = "transaction.pending_approval"
+	UpdateCompleted       UpdateType = "transaction.completed"
+	UpdateFailed          UpdateType = "transaction.failed"
+	UpdateCancelled       UpdateType = "transaction.cancelled"
+	UpdateReversed        UpdateType = "transaction.reversed"
+	UpdateDisputed        UpdateType = "transaction.disputed"
+	UpdateSettled         UpdateType = "transaction.settled"
+)
+
+// updateTypes maps the lifecycle events customers follow to their updates.
+var updateTypes = map[domain.TransactionEventType]UpdateType{
+	domain.TransactionEventInitiated:     UpdateCreated,
+	domain.TransactionEventHeldForReview: UpdatePendingApproval,
+	domain.TransactionEventDelivered:     UpdateCompleted,
+	domain.TransactionEventFailed:        UpdateFailed,
+	domain.TransactionEventCancelled:     UpdateCancelled,
+	domain.TransactionEventReversed:      UpdateReversed,
+	domain.TransactionEventDisputed:      UpdateDisputed,
+	domain.TransactionEventConfirmed:     UpdateSettled,
+}
+
+// Update is one status change of a transaction, as sent to its parties.
+type Update struct {
+	ID          uuid.UUID          `json:"id"`
+	Type        UpdateType         `json:"type"`
+	OccurredAt  time.Time          `json:"occurred_at"`
+	Transaction TransactionSummary `json:"transaction"`
+}
+
+// TransactionSummary is what the parties see of the transaction.
+type TransactionSummary struct {
+	ID                uuid.UUID                `json:"id"`
+	Reference         string                   `json:"reference"`
+	Status            domain.TransactionStatus `json:"status"`
+	SenderID          uuid.UUID                `json:"sender_id"`
+	ReceiverID        uuid.UUID                `json:"receiver_id"`
+	Amount            decimal.Decimal          `json:"amount"`
+	Currency          domain.Currency          `json:"currency"`
+	ConvertedAmount   decimal.Decimal          `json:"converted_amount"`
+	ConvertedCurrency domain.Currency          `json:"converted_currency"`
+	FeeAmount         decimal.Decimal          `json:"fee_amount"`
+	CreatedAt         time.Time                `json:"created_at"`
+	CompletedAt       *time.Time               `json:"completed_at,omitempty"`
+}
+
+// Bus carries updates between instances. Delivery is best effort.
+type Bus interface {
+	Publish(ctx context.Context, u *Update) error
+	// Subscribe calls fn for each update until ctx is done or the
+	// subscription fails.
+	Subscribe(ctx context.Context, fn func(*Update)) error
+}
+
+// RedisBus is a Bus over Redis pub/sub.
+type RedisBus struct {
+	client *redis.Client
+}
+
+func NewRedisBus(client *redis.Client) *RedisBus {
+	return &RedisBus{client: client}
+}
+
+func (b *RedisBus) Publish(ctx context.Context, u *Update) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, Channel, data).Err()
+}
+
+func (b *RedisBus) Subscribe(ctx context.Context, fn func(*Update)) error {
+	sub := b.client.Subscribe(ctx, Channel)
+	defer sub.Close()
+	// Wait for the subscription to be confirmed so failures are reported.
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var u Update
+			if err := json.Unmarshal([]byte(msg.Payload), &u); err != nil {
+				continue
+			}
+			fn(&u)
+		}
+	}
+}
+
+// EventStore is the transaction event stream the payment and settlement
+// services append to.
+type EventStore interface {
+	Append(ctx context.Context, e *domain.TransactionEvent) error
+	ListByTransaction(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionEvent, error)
+}
+
+type TransactionFinder interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
+}
+
+// Stream is an EventStore that also announces the events customers follow
+// on a Bus.
+type Stream struct {
+	EventStore
+	txs    TransactionFinder
+	bus    Bus
+	logger logger.Logger
+}
+
+// NewStream wraps store so that events appended to it are announced on bus.
+func NewStream(store EventStore, txs TransactionFinder, bus Bus, log logger.Logger) *Stream {
+	return &Stream{EventStore: store, txs: txs, bus: bus, logger: log}
+}
+
+// Append stores e and then announces it in the background, so that a slow
+// bus never holds up a payment.
+func (s *Stream) Append(ctx context.Context, e *domain.TransactionEvent) error {
+	if err := s.EventStore.Append(ctx, e); err != nil {
+		return err
+	}
+	if _, ok := updateTypes[e.Type]; ok {
+		event := *e
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := s.Publish(ctx, &event); err != nil {
+				s.logger.Warn("Failed to announce transaction update", map[string]interface{}{
+					"transaction_id": event.TransactionID,
+					"event":          event.Type,
+					"error":          err.Error(),
+				})
+			}
+		}()
+	}
+	return nil
+}
+
+// Publish announces e with the transaction as it now stands. Events
+// customers do not follow are ignored.
+func (s *Stream) Publish(ctx context.Context, e *domain.TransactionEvent) error {
+	updateType, ok := updateTypes[e.Type]
+	if !ok {
+		return nil
+	}
+	tx, err := s.txs.FindByID(ctx, e.TransactionID)
+	if err != nil {
+		return err
+	}
+	return s.bus.Publish(ctx, &Update{
+		ID:         e.ID,
+		Type:       updateType,
+		OccurredAt: e.CreatedAt.UTC(),
+		Transaction: TransactionSummary{
+			ID:                tx.ID,
+			Reference:         tx.Reference,
+			Status:            tx.Status,
+			SenderID:          tx.SenderID,
+			ReceiverID:        tx.ReceiverID,
+			Amount:            tx.Amount,
+			Currency:          tx.Currency,
+			ConvertedAmount:   tx.ConvertedAmount,
+			ConvertedCurrency: tx.ConvertedCurrency,
+			FeeAmount:         tx.FeeAmount,
+			CreatedAt:         tx.CreatedAt,
+			CompletedAt:       tx.CompletedAt,
+		},
+	})
+}
@@ -0,0 +1,154 @@
+package txstream
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memBus hands published updates straight to its subscriber.
+type memBus struct {
+	mu        sync.Mutex
+	published []*Update
+	fn        func(*Update)
+}
+
+func (b *memBus) Publish(ctx context.Context, u *Update) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, u)
+	if b.fn != nil {
+		b.fn(u)
+	}
+	return nil
+}
+
+func (b *memBus) Subscribe(ctx context.Context, fn func(*Update)) error {
+	b.mu.Lock()
+	b.fn = fn
+	b.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *memBus) subscribed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fn != nil
+}
+
+type memEvents struct {
+	events []*domain.TransactionEvent
+}
+
+func (m *memEvents) Append(ctx context.Context, e *domain.TransactionEvent) error {
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *memEvents) ListByTransaction(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionEvent, error) {
+	return m.events, nil
+}
+
+type memTxs map[uuid.UUID]*domain.Transaction
+
+func (m memTxs) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	return m[id], nil
+}
+
+func update(sender, receiver uuid.UUID) *Update {
+	return &Update{ID: uuid.New(), Type: UpdateCompleted, Transaction: TransactionSummary{ID: uuid.New(), SenderID: sender, ReceiverID: receiver}}
+}
+
+func TestStreamPublishesFollowedEvents(t *testing.T) {
+	ctx := context.Background()
+	tx := &domain.Transaction{
+		ID: uuid.New(), Reference: "KYD-1", Status: domain.TransactionStatusDisputed,
+		SenderID: uuid.New(), ReceiverID: uuid.New(), Amount: decimal.NewFromInt(25), Currency: domain.MWK,
+	}
+	bus := &memBus{}
+	store := &memEvents{}
+	stream := NewStream(store, memTxs{tx.ID: tx}, bus, logger.NewNop())
+
+	require.NoError(t, stream.Publish(ctx, &domain.TransactionEvent{ID: uuid.New(), TransactionID: tx.ID, Type: domain.TransactionEventDisputed, CreatedAt: time.Now()}))
+	require.NoError(t, stream.Publish(ctx, &domain.TransactionEvent{ID: uuid.New(), TransactionID: tx.ID, Type: domain.TransactionEventConverted}))
+
+	require.Len(t, bus.published, 1)
+	u := bus.published[0]
+	assert.Equal(t, UpdateDisputed, u.Type)
+	assert.Equal(t, tx.ID, u.Transaction.ID)
+	assert.Equal(t, domain.TransactionStatusDisputed, u.Transaction.Status)
+	assert.Equal(t, tx.ReceiverID, u.Transaction.ReceiverID)
+
+	// Appending stores the event whether or not it is announced
+	require.NoError(t, stream.Append(ctx, &domain.TransactionEvent{ID: uuid.New(), TransactionID: tx.ID, Type: domain.TransactionEventCompliancePassed}))
+	assert.Len(t, store.events, 1)
+}
+
+func TestHubDeliversToBothParties(t *testing.T) {
+	hub := NewHub(&memBus{}, logger.NewNop())
+	sender, receiver, bystander := uuid.New(), uuid.New(), uuid.New()
+	senderSub := hub.Subscribe(sender)
+	receiverSub := hub.Subscribe(receiver)
+	bystanderSub := hub.Subscribe(bystander)
+
+	u := update(sender, receiver)
+	hub.Deliver(u)
+	assert.Equal(t, u, <-senderSub.C)
+	assert.Equal(t, u, <-receiverSub.C)
+	assert.Empty(t, bystanderSub.C)
+
+	// A transfer between one's own wallets arrives once
+	own := update(sender, sender)
+	hub.Deliver(own)
+	assert.Equal(t, own, <-senderSub.C)
+	assert.Empty(t, senderSub.C)
+
+	hub.Unsubscribe(senderSub)
+	hub.Unsubscribe(senderSub)
+	_, open := <-senderSub.C
+	assert.False(t, open)
+	assert.Equal(t, 2, hub.Connections())
+}
+
+func TestHubDropsSubscribersThatFallBehind(t *testing.T) {
+	hub := NewHub(&memBus{}, logger.NewNop())
+	userID := uuid.New()
+	slow := hub.Subscribe(userID)
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		hub.Deliver(update(userID, uuid.New()))
+	}
+	received := 0
+	for range slow.C {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+	assert.Zero(t, hub.Connections())
+}
+
+func TestHubReceivesFromBus(t *testing.T) {
+	bus := &memBus{}
+	hub := NewHub(bus, logger.NewNop())
+	userID := uuid.New()
+	sub := hub.Subscribe(userID)
+	hub.Start()
+	require.Eventually(t, bus.subscribed, time.Second, time.Millisecond)
+
+	u := update(uuid.New(), userID)
+	require.NoError(t, bus.Publish(context.Background(), u))
+	assert.Equal(t, u, <-sub.C)
+
+	hub.Stop()
+	_, open := <-sub.C
+	assert.False(t, open)
+}
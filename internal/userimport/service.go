@@ -0,0 +1,450 @@
+// Package userimport onboards people in bulk from a CSV, such as a corporate
+// customer's employees, as provisional accounts invited by email.
+package userimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+	"kyd/pkg/validator"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidFile = errors.New("invalid import file")
+	ErrNotFound    = errors.New("user import not found")
+)
+
+// Repository persists imports and their rows.
+type Repository interface {
+	// CreateImport stores an import with all its rows at once.
+	CreateImport(ctx context.Context, imp *domain.UserImport, rows []domain.UserImportRow) error
+	GetImport(ctx context.Context, id uuid.UUID) (*domain.UserImport, error)
+	ListImports(ctx context.Context, limit, offset int) ([]domain.UserImport, int, error)
+	// ListRows pages through an import's rows by line, optionally only
+	// those with status.
+	ListRows(ctx context.Context, importID uuid.UUID, status domain.UserImportRowStatus, limit, offset int) ([]domain.UserImportRow, int, error)
+	// ClaimImport moves the oldest queued import to processing and returns
+	// it, or nil when there is none, so concurrent workers never pick the
+	// same one. Imports left in processing by a worker that died are
+	// reclaimed after a lease.
+	ClaimImport(ctx context.Context, now time.Time) (*domain.UserImport, error)
+	PendingRows(ctx context.Context, importID uuid.UUID, limit int) ([]domain.UserImportRow, error)
+	// FinishRow records a pending row's outcome and counts it on its import.
+	FinishRow(ctx context.Context, row *domain.UserImportRow) error
+	CompleteImport(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// Users looks up existing accounts.
+type Users interface {
+	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+}
+
+// Inviter creates a provisional account and emails its holder an invitation.
+type Inviter interface {
+	Invite(ctx context.Context, user *domain.User) error
+}
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultMaxRows      = 5000
+	rowBatchSize        = 100
+)
+
+// columns are the CSV columns in the order validation errors are reported.
+var columns = []struct {
+	name     string
+	field    string // the UserImportRecord field
+	required bool
+}{
+	{"email", "Email", true},
+	{"phone", "Phone", true},
+	{"first_name", "FirstName", true},
+	{"last_name", "LastName", true},
+	{"country_code", "CountryCode", true},
+	{"date_of_birth", "DateOfBirth", false},
+	{"city", "City", false},
+	{"postal_code", "PostalCode", false},
+	{"tax_id", "TaxID", false},
+}
+
+type Service struct {
+	repo      Repository
+	users     Users
+	inviter   Inviter
+	validator *validator.Validator
+	logger    logger.Logger
+	maxRows   int
+	interval  time.Duration
+	now       func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService(repo Repository, users Users, inviter Inviter, cfg config.UserImportConfig, log logger.Logger) *Service {
+	s := &Service{
+		repo:      repo,
+		users:     users,
+		inviter:   inviter,
+		validator: validator.New(),
+		logger:    log,
+		maxRows:   defaultMaxRows,
+		interval:  defaultPollInterval,
+		now:       time.Now,
+		stop:      make(chan struct{}),
+	}
+	if cfg.MaxRows > 0 {
+		s.maxRows = cfg.MaxRows
+	}
+	if cfg.PollInterval > 0 {
+		s.interval = cfg.PollInterval
+	}
+	return s
+}
+
+// UploadRequest is a CSV of people to onboard.
+type UploadRequest struct {
+	FileName string
+	// Organization is who the people belong to, for the operators' benefit.
+	Organization string
+	Content      io.Reader
+	CreatedBy    *uuid.UUID
+}
+
+// Upload validates every row of a CSV and queues the valid ones for the
+// worker. The file must have a header naming at least the required columns;
+// rows that fail validation are kept with their errors and never imported.
+// Only a file that cannot be read at all is rejected as a whole.
+func (s *Service) Upload(ctx context.Context, req *UploadRequest) (*domain.UserImport, []domain.UserImportRow, error) {
+	records, lines, err := s.parse(req.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := s.now()
+	imp := &domain.UserImport{
+		ID:           uuid.New(),
+		FileName:     strings.TrimSpace(req.FileName),
+		Organization: strings.TrimSpace(req.Organization),
+		Status:       domain.UserImportQueued,
+		TotalRows:    len(records),
+		CreatedBy:    req.CreatedBy,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	rows := make([]domain.UserImportRow, len(records))
+	emails := make(map[string]int)
+	phones := make(map[string]int)
+	for i := range records {
+		row := &rows[i]
+		row.ImportID = imp.ID
+		row.Line = lines[i]
+		row.Record = records[i]
+		row.Errors = s.validate(&row.Record)
+
+		if first, ok := emails[row.Record.Email]; ok && row.Record.Email != "" {
+			row.Errors = append(row.Errors, domain.UserImportFieldError{Field: "email", Message: fmt.Sprintf("duplicates line %d", first)})
+		} else {
+			emails[row.Record.Email] = row.Line
+		}
+		if first, ok := phones[row.Record.Phone]; ok && row.Record.Phone != "" {
+			row.Errors = append(row.Errors, domain.UserImportFieldError{Field: "phone", Message: fmt.Sprintf("duplicates line %d", first)})
+		} else {
+			phones[row.Record.Phone] = row.Line
+		}
+
+		if len(row.Errors) == 0 {
+			exists, err := s.users.ExistsByEmail(ctx, row.Record.Email)
+			if err != nil {
+				return nil, nil, err
+			}
+			if exists {
+				row.Errors = append(row.Errors, domain.UserImportFieldError{Field: "email", Message: "is already registered"})
+			}
+		}
+
+		if len(row.Errors) > 0 {
+			row.Status = domain.UserImportRowInvalid
+			imp.InvalidRows++
+			continue
+		}
+		// The account's ID is reserved now, so that a worker resuming an
+		// interrupted import recognises the accounts it already created.
+		id := uuid.New()
+		row.Status = domain.UserImportRowPending
+		row.UserID = &id
+	}
+
+	if imp.ValidRows() == 0 {
+		imp.Status = domain.UserImportCompleted
+		imp.CompletedAt = &now
+	}
+	if err := s.repo.CreateImport(ctx, imp, rows); err != nil {
+		return nil, nil, err
+	}
+	s.logger.Info("User import queued", map[string]interface{}{
+		"import_id":    imp.ID,
+		"total_rows":   imp.TotalRows,
+		"invalid_rows": imp.InvalidRows,
+	})
+	return imp, rows, nil
+}
+
+// parse reads the records of a CSV and the line each starts on.
+func (s *Service) parse(content io.Reader) ([]domain.UserImportRecord, []int, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("%w: the file is empty", ErrInvalidFile)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, c := range columns {
+			if c.name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, nil, fmt.Errorf("%w: unknown column %q", ErrInvalidFile, name)
+		}
+		if _, dup := index[name]; dup {
+			return nil, nil, fmt.Errorf("%w: column %q appears twice", ErrInvalidFile, name)
+		}
+		index[name] = i
+	}
+	for _, c := range columns {
+		if _, ok := index[c.name]; c.required && !ok {
+			return nil, nil, fmt.Errorf("%w: missing column %q", ErrInvalidFile, c.name)
+		}
+	}
+
+	var (
+		records []domain.UserImportRecord
+		lines   []int
+	)
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		if len(records) == s.maxRows {
+			return nil, nil, fmt.Errorf("%w: more than %d rows, split the file", ErrInvalidFile, s.maxRows)
+		}
+		line, _ := r.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := index[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		records = append(records, domain.UserImportRecord{
+			Email:       strings.ToLower(get("email")),
+			Phone:       strings.ReplaceAll(get("phone"), " ", ""),
+			FirstName:   get("first_name"),
+			LastName:    get("last_name"),
+			CountryCode: strings.ToUpper(get("country_code")),
+			DateOfBirth: get("date_of_birth"),
+			City:        get("city"),
+			PostalCode:  get("postal_code"),
+			TaxID:       get("tax_id"),
+		})
+		lines = append(lines, line)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%w: the file has no rows", ErrInvalidFile)
+	}
+	return records, lines, nil
+}
+
+// validate reports a record's field errors by column, in column order.
+func (s *Service) validate(rec *domain.UserImportRecord) domain.UserImportErrors {
+	failed := s.validator.ValidateStructured(rec)
+	if failed == nil {
+		return nil
+	}
+	var errs domain.UserImportErrors
+	for _, c := range columns {
+		if msg, ok := failed[c.field]; ok {
+			errs = append(errs, domain.UserImportFieldError{Field: c.name, Message: msg})
+		}
+	}
+	if rec.DateOfBirth != "" {
+		if dob, err := time.Parse("2006-01-02", rec.DateOfBirth); err == nil && dob.After(s.now()) {
+			errs = append(errs, domain.UserImportFieldError{Field: "date_of_birth", Message: "is in the future"})
+		}
+	}
+	return errs
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*domain.UserImport, error) {
+	return s.repo.GetImport(ctx, id)
+}
+
+func (s *Service) List(ctx context.Context, limit, offset int) ([]domain.UserImport, int, error) {
+	return s.repo.ListImports(ctx, limit, offset)
+}
+
+// Rows returns an import's per-row results, optionally only those with status.
+func (s *Service) Rows(ctx context.Context, importID uuid.UUID, status domain.UserImportRowStatus, limit, offset int) ([]domain.UserImportRow, int, error) {
+	if _, err := s.repo.GetImport(ctx, importID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListRows(ctx, importID, status, limit, offset)
+}
+
+// Start runs the import worker until Stop is called.
+func (s *Service) Start() {
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.RunDue(context.Background())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("User import worker started", map[string]interface{}{"interval": s.interval.String()})
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// RunDue processes queued imports one after another until none is left.
+func (s *Service) RunDue(ctx context.Context) {
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		imp, err := s.repo.ClaimImport(ctx, s.now())
+		if err != nil {
+			s.logger.Error("Failed to claim user import", map[string]interface{}{"error": err.Error()})
+			return
+		}
+		if imp == nil {
+			return
+		}
+		if err := s.process(ctx, imp); err != nil {
+			// The import stays in processing; finished rows are recorded, so
+			// it resumes where it stopped once reclaimed.
+			s.logger.Error("User import interrupted", map[string]interface{}{
+				"error":     err.Error(),
+				"import_id": imp.ID,
+			})
+			return
+		}
+	}
+}
+
+func (s *Service) process(ctx context.Context, imp *domain.UserImport) error {
+	for {
+		rows, err := s.repo.PendingRows(ctx, imp.ID, rowBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for i := range rows {
+			s.invite(ctx, &rows[i])
+			if err := s.repo.FinishRow(ctx, &rows[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.repo.CompleteImport(ctx, imp.ID, s.now()); err != nil {
+		return err
+	}
+	done, err := s.repo.GetImport(ctx, imp.ID)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("User import completed", map[string]interface{}{
+		"import_id": imp.ID,
+		"invited":   done.InvitedRows,
+		"failed":    done.FailedRows,
+	})
+	return nil
+}
+
+// invite creates the provisional account for a pending row and sets the
+// row's outcome.
+func (s *Service) invite(ctx context.Context, row *domain.UserImportRow) {
+	rec := row.Record
+	user := &domain.User{
+		ID:          *row.UserID,
+		Email:       rec.Email,
+		Phone:       rec.Phone,
+		FirstName:   rec.FirstName,
+		LastName:    rec.LastName,
+		UserType:    domain.UserTypeIndividual,
+		CountryCode: rec.CountryCode,
+		City:        rec.City,
+		PostalCode:  rec.PostalCode,
+		TaxID:       rec.TaxID,
+	}
+	if dob, err := time.Parse("2006-01-02", rec.DateOfBirth); err == nil {
+		user.DateOfBirth = &dob
+	}
+
+	err := s.inviter.Invite(ctx, user)
+	if errors.Is(err, kyderrors.ErrUserAlreadyExists) {
+		// Either someone registered the address since the upload, or a
+		// previous run created this very account and died before recording it.
+		if existing, ferr := s.users.FindByID(ctx, user.ID); ferr == nil && existing != nil {
+			err = nil
+		}
+	}
+
+	now := s.now()
+	row.ProcessedAt = &now
+	switch {
+	case errors.Is(err, kyderrors.ErrUserAlreadyExists):
+		row.Status = domain.UserImportRowFailed
+		row.Errors = domain.UserImportErrors{{Field: "email", Message: "is already registered"}}
+		row.UserID = nil
+	case err != nil:
+		row.Status = domain.UserImportRowFailed
+		row.Errors = domain.UserImportErrors{{Message: err.Error()}}
+		row.UserID = nil
+	default:
+		row.Status = domain.UserImportRowInvited
+	}
+}
@@ -0,0 +1,265 @@
+package userimport
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	kyderrors "kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRepository struct {
+	imports map[uuid.UUID]*domain.UserImport
+	rows    map[uuid.UUID][]domain.UserImportRow
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		imports: make(map[uuid.UUID]*domain.UserImport),
+		rows:    make(map[uuid.UUID][]domain.UserImportRow),
+	}
+}
+
+func (m *memoryRepository) CreateImport(ctx context.Context, imp *domain.UserImport, rows []domain.UserImportRow) error {
+	cp := *imp
+	m.imports[imp.ID] = &cp
+	m.rows[imp.ID] = append([]domain.UserImportRow(nil), rows...)
+	return nil
+}
+
+func (m *memoryRepository) GetImport(ctx context.Context, id uuid.UUID) (*domain.UserImport, error) {
+	imp, ok := m.imports[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *imp
+	return &cp, nil
+}
+
+func (m *memoryRepository) ListImports(ctx context.Context, limit, offset int) ([]domain.UserImport, int, error) {
+	var out []domain.UserImport
+	for _, imp := range m.imports {
+		out = append(out, *imp)
+	}
+	return out, len(out), nil
+}
+
+func (m *memoryRepository) ListRows(ctx context.Context, importID uuid.UUID, status domain.UserImportRowStatus, limit, offset int) ([]domain.UserImportRow, int, error) {
+	var out []domain.UserImportRow
+	for _, row := range m.rows[importID] {
+		if status == "" || row.Status == status {
+			out = append(out, row)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Line < out[j].Line })
+	return out, len(out), nil
+}
+
+func (m *memoryRepository) ClaimImport(ctx context.Context, now time.Time) (*domain.UserImport, error) {
+	for _, imp := range m.imports {
+		if imp.Status == domain.UserImportQueued {
+			imp.Status = domain.UserImportProcessing
+			imp.StartedAt = &now
+			cp := *imp
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryRepository) PendingRows(ctx context.Context, importID uuid.UUID, limit int) ([]domain.UserImportRow, error) {
+	rows, _, _ := m.ListRows(ctx, importID, domain.UserImportRowPending, limit, 0)
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (m *memoryRepository) FinishRow(ctx context.Context, row *domain.UserImportRow) error {
+	for i, r := range m.rows[row.ImportID] {
+		if r.Line == row.Line && r.Status == domain.UserImportRowPending {
+			m.rows[row.ImportID][i] = *row
+			imp := m.imports[row.ImportID]
+			imp.ProcessedRows++
+			if row.Status == domain.UserImportRowInvited {
+				imp.InvitedRows++
+			} else {
+				imp.FailedRows++
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepository) CompleteImport(ctx context.Context, id uuid.UUID, at time.Time) error {
+	m.imports[id].Status = domain.UserImportCompleted
+	m.imports[id].CompletedAt = &at
+	return nil
+}
+
+// directory is both the existing accounts and the inviter that adds to them.
+type directory struct {
+	byEmail map[string]*domain.User
+	fail    map[string]error
+}
+
+func newDirectory(emails ...string) *directory {
+	d := &directory{byEmail: make(map[string]*domain.User), fail: make(map[string]error)}
+	for _, e := range emails {
+		d.byEmail[e] = &domain.User{ID: uuid.New(), Email: e}
+	}
+	return d
+}
+
+func (d *directory) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	_, ok := d.byEmail[email]
+	return ok, nil
+}
+
+func (d *directory) FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	for _, u := range d.byEmail {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *directory) Invite(ctx context.Context, user *domain.User) error {
+	if err := d.fail[user.Email]; err != nil {
+		return err
+	}
+	if _, ok := d.byEmail[user.Email]; ok {
+		return kyderrors.ErrUserAlreadyExists
+	}
+	d.byEmail[user.Email] = user
+	return nil
+}
+
+const header = "email,phone,first_name,last_name,country_code,date_of_birth,city,postal_code,tax_id\n"
+
+func TestUploadValidatesEachRow(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	s := NewService(repo, newDirectory("taken@example.com"), newDirectory(), config.UserImportConfig{}, logger.NewNop())
+
+	csv := "\ufeff" + header +
+		"Ada@Example.com,+265991000001,Ada,Banda,mw,1990-04-01,Lilongwe,,TIN-1\n" +
+		"not-an-email,+265991000002,Bo,Phiri,MW,,,,\n" +
+		"ada@example.com,+265991000003,Ada,Again,MW,,,,\n" +
+		"taken@example.com,+265991000004,Cy,Mbewe,MW,,,,\n" +
+		"dee@example.com,0991000005,Dee,,MWI,31/12/1990,,,\n"
+	imp, rows, err := s.Upload(ctx, &UploadRequest{FileName: "staff.csv", Organization: "Acme", Content: strings.NewReader(csv)})
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserImportQueued, imp.Status)
+	assert.Equal(t, 5, imp.TotalRows)
+	assert.Equal(t, 4, imp.InvalidRows)
+	assert.Equal(t, 1, imp.ValidRows())
+
+	require.Len(t, rows, 5)
+	assert.Equal(t, 2, rows[0].Line, "the header is line 1")
+	assert.Equal(t, domain.UserImportRowPending, rows[0].Status)
+	assert.Equal(t, "ada@example.com", rows[0].Record.Email)
+	assert.Equal(t, "MW", rows[0].Record.CountryCode)
+	assert.NotNil(t, rows[0].UserID)
+
+	assert.Equal(t, domain.UserImportErrors{{Field: "email", Message: "Invalid email address"}}, rows[1].Errors)
+	assert.Equal(t, domain.UserImportErrors{{Field: "email", Message: "duplicates line 2"}}, rows[2].Errors)
+	assert.Equal(t, domain.UserImportErrors{{Field: "email", Message: "is already registered"}}, rows[3].Errors)
+	var fields []string
+	for _, e := range rows[4].Errors {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{"phone", "last_name", "country_code", "date_of_birth"}, fields)
+	assert.Nil(t, rows[4].UserID)
+}
+
+func TestUploadRejectsUnreadableFiles(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(newMemoryRepository(), newDirectory(), newDirectory(), config.UserImportConfig{MaxRows: 2}, logger.NewNop())
+
+	for name, content := range map[string]string{
+		"empty":          "",
+		"no rows":        header,
+		"missing column": "email,phone,first_name,last_name\nx@example.com,+265991000001,X,Y\n",
+		"unknown column": "email,phone,first_name,last_name,country_code,salary\n",
+		"too many rows":  header + strings.Repeat("a@example.com,+265991000001,A,B,MW,,,,\n", 3),
+	} {
+		_, _, err := s.Upload(ctx, &UploadRequest{Content: strings.NewReader(content)})
+		assert.ErrorIs(t, err, ErrInvalidFile, name)
+	}
+}
+
+func TestRunDueInvitesPendingRows(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	users := newDirectory()
+	s := NewService(repo, users, users, config.UserImportConfig{}, logger.NewNop())
+
+	csv := header +
+		"one@example.com,+265991000001,One,Banda,MW,1990-04-01,Lilongwe,,\n" +
+		"two@example.com,+265991000002,Two,Phiri,MW,,,,\n" +
+		"three@example.com,+265991000003,Three,Mbewe,MW,,,,\n" +
+		"bad,+265991000004,Bad,Row,MW,,,,\n"
+	imp, _, err := s.Upload(ctx, &UploadRequest{Content: strings.NewReader(csv)})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, imp.Progress())
+
+	// Someone registers with one of the addresses before the worker runs
+	users.byEmail["two@example.com"] = &domain.User{ID: uuid.New(), Email: "two@example.com"}
+	users.fail["three@example.com"] = errors.New("smtp unavailable")
+
+	s.RunDue(ctx)
+
+	done, err := s.Get(ctx, imp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserImportCompleted, done.Status)
+	assert.Equal(t, 3, done.ProcessedRows)
+	assert.Equal(t, 1, done.InvitedRows)
+	assert.Equal(t, 2, done.FailedRows)
+	assert.Equal(t, 1.0, done.Progress())
+
+	rows, _, err := s.Rows(ctx, imp.ID, "", 50, 0)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserImportRowInvited, rows[0].Status)
+	invited := users.byEmail["one@example.com"]
+	require.NotNil(t, invited)
+	assert.Equal(t, *rows[0].UserID, invited.ID)
+	assert.Equal(t, "Lilongwe", invited.City)
+	require.NotNil(t, invited.DateOfBirth)
+	assert.Equal(t, "1990-04-01", invited.DateOfBirth.Format("2006-01-02"))
+
+	assert.Equal(t, domain.UserImportRowFailed, rows[1].Status)
+	assert.Equal(t, "is already registered", rows[1].Errors[0].Message)
+	assert.Nil(t, rows[1].UserID)
+	assert.Equal(t, "smtp unavailable", rows[2].Errors[0].Message)
+	assert.Equal(t, domain.UserImportRowInvalid, rows[3].Status)
+}
+
+func TestResumedImportKeepsAccountsAlreadyCreated(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	users := newDirectory()
+	s := NewService(repo, users, users, config.UserImportConfig{}, logger.NewNop())
+
+	imp, rows, err := s.Upload(ctx, &UploadRequest{Content: strings.NewReader(header + "one@example.com,+265991000001,One,Banda,MW,,,,\n")})
+	require.NoError(t, err)
+
+	// A previous worker created the account but died before recording it
+	users.byEmail["one@example.com"] = &domain.User{ID: *rows[0].UserID, Email: "one@example.com"}
+
+	s.RunDue(ctx)
+	done, _ := s.Get(ctx, imp.ID)
+	assert.Equal(t, 1, done.InvitedRows)
+	assert.Equal(t, 0, done.FailedRows)
+}
@@ -0,0 +1,192 @@
+package virusscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrSizeLimit is returned when a file is larger than clamd's StreamMaxLength.
+var ErrSizeLimit = errors.New("file exceeds the clamd stream size limit")
+
+// DefaultChunkSize is how much of a file is sent to clamd per INSTREAM chunk.
+const DefaultChunkSize = 64 << 10
+
+// ClamAVConfig says how to reach clamd.
+type ClamAVConfig struct {
+	// Address is tcp://host:port or unix:///path/to/clamd.sock. A bare
+	// host:port is taken as TCP and a bare path as a unix socket.
+	Address string
+	// ConnectTimeout bounds dialing clamd.
+	ConnectTimeout time.Duration
+	// ScanTimeout bounds one whole scan, from the first byte sent to the
+	// verdict.
+	ScanTimeout time.Duration
+	ChunkSize   int
+}
+
+// ClamAVScanService scans files with a clamd daemon over its INSTREAM
+// protocol, so files are streamed in chunks rather than shared on disk and
+// large documents never have to fit in memory.
+type ClamAVScanService struct {
+	network, address string
+	cfg              ClamAVConfig
+}
+
+// NewClamAVScanService returns a scanner for the clamd at cfg.Address.
+func NewClamAVScanService(cfg ClamAVConfig) (*ClamAVScanService, error) {
+	network, address, err := parseAddress(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultChunkSize
+	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = 5 * time.Second
+	}
+	if cfg.ScanTimeout <= 0 {
+		cfg.ScanTimeout = time.Minute
+	}
+	return &ClamAVScanService{network: network, address: address, cfg: cfg}, nil
+}
+
+func parseAddress(addr string) (network, address string, err error) {
+	switch {
+	case strings.HasPrefix(addr, "tcp://"):
+		return "tcp", strings.TrimPrefix(addr, "tcp://"), nil
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://"), nil
+	case strings.HasPrefix(addr, "/"):
+		return "unix", addr, nil
+	case addr != "":
+		return "tcp", addr, nil
+	}
+	return "", "", errors.New("clamd address is required")
+}
+
+func (s *ClamAVScanService) dial(ctx context.Context, timeout time.Duration) (net.Conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, s.cfg.ConnectTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(dialCtx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("connect to clamd: %w", err)
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Ping checks that clamd is up.
+func (s *ClamAVScanService) Ping(ctx context.Context) error {
+	conn, err := s.dial(ctx, s.cfg.ConnectTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return fmt.Errorf("ping clamd: %w", err)
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+	return nil
+}
+
+// Scan streams r to clamd and returns its verdict.
+func (s *ClamAVScanService) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, err := s.dial(ctx, s.cfg.ScanTimeout)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	// Stop streaming if the caller gives up; clamd would otherwise keep
+	// reading until the deadline.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if err := s.stream(conn, r); err != nil {
+		// clamd closes the connection once a stream is too large, and says
+		// why before doing so.
+		if reply, rerr := readReply(conn); rerr == nil && strings.HasSuffix(reply, "ERROR") {
+			return parseReply(reply)
+		}
+		return Result{}, fmt.Errorf("stream to clamd: %w", err)
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, err
+	}
+	return parseReply(reply)
+}
+
+// stream sends r as INSTREAM chunks, each a big-endian length and the
+// data, ended by a zero-length chunk.
+func (s *ClamAVScanService) stream(w io.Writer, r io.Reader) error {
+	if _, err := w.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+	buf := make([]byte, 4+s.cfg.ChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// readReply reads one null-terminated clamd reply.
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return "", fmt.Errorf("read clamd reply: %w", err)
+	}
+	return string(bytes.TrimSpace(bytes.TrimRight(reply, "\x00"))), nil
+}
+
+// parseReply interprets "stream: OK", "stream: <signature> FOUND" and
+// "<reason> ERROR".
+func parseReply(reply string) (Result, error) {
+	msg := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case msg == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(msg, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(msg, " FOUND")}, nil
+	case strings.HasPrefix(msg, "INSTREAM size limit exceeded"):
+		return Result{}, ErrSizeLimit
+	case strings.HasSuffix(msg, "ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSpace(strings.TrimSuffix(msg, "ERROR")))
+	}
+	return Result{}, fmt.Errorf("unexpected clamd reply %q", reply)
+}
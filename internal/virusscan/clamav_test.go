@@ -0,0 +1,172 @@
+package virusscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClamd answers INSTREAM and PING like clamd, flagging streams that
+// contain the EICAR string and refusing those over maxSize.
+type fakeClamd struct {
+	ln      net.Listener
+	maxSize int
+	chunks  chan int
+}
+
+func startFakeClamd(t *testing.T, network, address string, maxSize int) *fakeClamd {
+	ln, err := net.Listen(network, address)
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeClamd{ln: ln, maxSize: maxSize, chunks: make(chan int, 1024)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch cmd {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var data bytes.Buffer
+		for {
+			var size [4]byte
+			if _, err := io.ReadFull(r, size[:]); err != nil {
+				return
+			}
+			n := int(binary.BigEndian.Uint32(size[:]))
+			if n == 0 {
+				break
+			}
+			select {
+			case f.chunks <- n:
+			default:
+			}
+			if data.Len()+n > f.maxSize {
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				return
+			}
+			if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+				return
+			}
+		}
+		if bytes.Contains(data.Bytes(), eicar) {
+			conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+			return
+		}
+		conn.Write([]byte("stream: OK\x00"))
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func TestClamAVScan(t *testing.T) {
+	clamd := startFakeClamd(t, "tcp", "127.0.0.1:0", 1<<20)
+	s, err := NewClamAVScanService(ClamAVConfig{Address: "tcp://" + clamd.ln.Addr().String(), ChunkSize: 1024})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, s.Ping(ctx))
+
+	res, err := s.Scan(ctx, strings.NewReader("a perfectly ordinary passport scan"))
+	require.NoError(t, err)
+	assert.False(t, res.Infected)
+
+	// A large document is streamed in chunks, with the signature split
+	// across two of them.
+	doc := append(bytes.Repeat([]byte("x"), 1000), eicar...)
+	doc = append(doc, bytes.Repeat([]byte("y"), 5000)...)
+	for len(clamd.chunks) > 0 {
+		<-clamd.chunks
+	}
+	res, err = s.Scan(ctx, bytes.NewReader(doc))
+	require.NoError(t, err)
+	assert.True(t, res.Infected)
+	assert.Equal(t, "Win.Test.EICAR_HDB-1", res.Signature)
+	assert.Equal(t, 6, len(clamd.chunks))
+
+	_, err = s.Scan(ctx, bytes.NewReader(make([]byte, 2<<20)))
+	assert.ErrorIs(t, err, ErrSizeLimit)
+}
+
+func TestClamAVScanOverUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "clamd.sock")
+	startFakeClamd(t, "unix", sock, 1<<20)
+	s, err := NewClamAVScanService(ClamAVConfig{Address: "unix://" + sock})
+	require.NoError(t, err)
+
+	res, err := s.Scan(context.Background(), bytes.NewReader(eicar))
+	require.NoError(t, err)
+	assert.True(t, res.Infected)
+}
+
+func TestClamAVScanTimesOut(t *testing.T) {
+	// A daemon that accepts but never answers.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	s, err := NewClamAVScanService(ClamAVConfig{Address: ln.Addr().String(), ScanTimeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = s.Scan(context.Background(), strings.NewReader("hello"))
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestClamAVUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s, err := NewClamAVScanService(ClamAVConfig{Address: addr})
+	require.NoError(t, err)
+	_, err = s.Scan(context.Background(), strings.NewReader("hello"))
+	assert.Error(t, err)
+
+	_, err = NewClamAVScanService(ClamAVConfig{})
+	assert.Error(t, err)
+}
+
+func TestMockFlagsEICAR(t *testing.T) {
+	res, err := Mock{}.Scan(context.Background(), bytes.NewReader(eicar))
+	require.NoError(t, err)
+	assert.True(t, res.Infected)
+	res, err = Mock{}.Scan(context.Background(), strings.NewReader("clean"))
+	require.NoError(t, err)
+	assert.False(t, res.Infected)
+}
@@ -0,0 +1,61 @@
+// Package virusscan checks uploaded files for malware before anyone opens
+// them.
+package virusscan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"kyd/pkg/config"
+)
+
+// Result is the verdict on one file.
+type Result struct {
+	Infected bool
+	// Signature names what was found, e.g. "Win.Test.EICAR_HDB-1".
+	Signature string
+}
+
+// Scanner scans a stream of file contents. An error means no verdict was
+// reached; the file must be treated as unscanned, not clean.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// eicar is the standard antivirus test file.
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// Mock passes every file except those containing the EICAR test string. It
+// stands in for clamd in local and test environments.
+type Mock struct{}
+
+func (Mock) Scan(_ context.Context, r io.Reader) (Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	if bytes.Contains(data, eicar) {
+		return Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return Result{}, nil
+}
+
+// FromConfig returns the scanner cfg selects, or nil when scanning is off.
+func FromConfig(cfg config.VirusScanConfig) (Scanner, error) {
+	switch cfg.Backend {
+	case "clamav":
+		return NewClamAVScanService(ClamAVConfig{
+			Address:        cfg.ClamAVAddress,
+			ConnectTimeout: cfg.ConnectTimeout,
+			ScanTimeout:    cfg.ScanTimeout,
+			ChunkSize:      cfg.ChunkSize,
+		})
+	case "mock":
+		return Mock{}, nil
+	case "", "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown virus scan backend %q", cfg.Backend)
+}
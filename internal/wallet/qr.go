@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"kyd/internal/domain"
+	"kyd/pkg/emvqr"
+	"kyd/pkg/errors"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidQRRequest = errors.New("invalid QR code request")
+	// ErrInvalidAddress is returned for wallet addresses that are not on
+	// record and fail their check digit, so were most likely mistyped.
+	ErrInvalidAddress = errors.New("wallet address is not valid: check the number")
+)
+
+// qrReferencePattern is what payee references may hold; the QR layout
+// allows 25 characters.
+var qrReferencePattern = regexp.MustCompile(`^[A-Za-z0-9 ./_-]{1,25}$`)
+
+// WalletQRCode is what a wallet's QR code carries, and the code itself as
+// Payload.
+type WalletQRCode struct {
+	WalletID      uuid.UUID        `json:"wallet_id"`
+	WalletAddress string           `json:"wallet_address"`
+	Currency      domain.Currency  `json:"currency"`
+	Amount        *decimal.Decimal `json:"amount,omitempty"`
+	Reference     string           `json:"reference,omitempty"`
+	Payload       string           `json:"payload"`
+}
+
+// ScannedQRCode is a scanned wallet QR code checked against our records:
+// the payee is the wallet's account holder as we know them, whatever name
+// the code carries.
+type ScannedQRCode struct {
+	WalletAddress          string           `json:"wallet_address"`
+	FormattedWalletAddress string           `json:"formatted_wallet_address"`
+	Name                   string           `json:"name"`
+	Currency               domain.Currency  `json:"currency"`
+	Amount                 *decimal.Decimal `json:"amount,omitempty"`
+	Reference              string           `json:"reference,omitempty"`
+}
+
+// QRCode returns the QR code for paying one of the user's wallets. With an
+// amount, the payer is asked for that amount; reference is passed on to
+// the payment.
+func (s *Service) QRCode(ctx context.Context, userID, walletID uuid.UUID, amount *decimal.Decimal, reference string) (*WalletQRCode, error) {
+	wallet, err := s.repo.FindByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.UserID != userID {
+		return nil, errors.ErrWalletNotFound
+	}
+	if wallet.Status != domain.WalletStatusActive {
+		return nil, errors.ErrWalletNotActive
+	}
+	if amount != nil && (!amount.IsPositive() || amount.Exponent() < -2) {
+		return nil, fmt.Errorf("%w: amount must be positive with at most 2 decimal places", ErrInvalidQRRequest)
+	}
+	reference = strings.TrimSpace(reference)
+	if reference != "" && !qrReferencePattern.MatchString(reference) {
+		return nil, fmt.Errorf("%w: reference must be up to 25 letters, digits, spaces or . / _ -", ErrInvalidQRRequest)
+	}
+	user, err := s.userRepo.FindByID(ctx, wallet.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch user details")
+	}
+
+	address := resolvedDisplayWalletAddress(wallet)
+	payload, err := emvqr.Encode(&emvqr.Payload{
+		Address:   address,
+		Currency:  string(wallet.Currency),
+		Amount:    amount,
+		Reference: reference,
+		Name:      fmt.Sprintf("%s %s", user.FirstName, user.LastName),
+		City:      user.City,
+		Country:   user.CountryCode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQRRequest, err)
+	}
+	return &WalletQRCode{
+		WalletID:      wallet.ID,
+		WalletAddress: address,
+		Currency:      wallet.Currency,
+		Amount:        amount,
+		Reference:     reference,
+		Payload:       payload,
+	}, nil
+}
+
+// DecodeQRCode reads a scanned wallet QR code and looks its wallet up, for
+// the app to confirm the payee before paying. Codes that were misread,
+// are for another scheme or name a wallet in another currency than the one
+// on record are refused.
+func (s *Service) DecodeQRCode(ctx context.Context, payload string) (*ScannedQRCode, error) {
+	p, err := emvqr.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.LookupWallet(ctx, p.Address)
+	if err != nil {
+		return nil, err
+	}
+	if string(info.Currency) != p.Currency {
+		return nil, fmt.Errorf("%w: the code is for %s but the wallet holds %s", emvqr.ErrUnsupported, p.Currency, info.Currency)
+	}
+	return &ScannedQRCode{
+		WalletAddress:          info.Address,
+		FormattedWalletAddress: formatWalletAddress(info.Address),
+		Name:                   info.Name,
+		Currency:               info.Currency,
+		Amount:                 p.Amount,
+		Reference:              p.Reference,
+	}, nil
+}
+
+// ValidAddress reports whether addr is a 16-digit wallet address ending in
+// the Luhn check digit of the rest, as addresses have been issued since
+// check digits were introduced. Older addresses may fail it and still be
+// on record.
+func ValidAddress(addr string) bool {
+	if len(addr) != 16 || nonDigitWalletChars.MatchString(addr) {
+		return false
+	}
+	return addressCheckDigit(addr[:15]) == addr[15]
+}
+
+// addressCheckDigit is the Luhn check digit of body, as on card numbers: it
+// catches any single mistyped digit and most swapped neighbours.
+func addressCheckDigit(body string) byte {
+	sum := 0
+	double := true
+	for i := len(body) - 1; i >= 0; i-- {
+		d := int(body[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func formatWalletAddress(addr string) string {
+	if len(addr) != 16 {
+		return addr
+	}
+	return fmt.Sprintf("%s %s %s %s", addr[0:4], addr[4:8], addr[8:12], addr[12:16])
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+
+	"kyd/internal/domain"
+	"kyd/pkg/emvqr"
+	"kyd/pkg/errors"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidAddress(t *testing.T) {
+	// A card-style number with a valid Luhn digit
+	assert.True(t, ValidAddress("4539102834756195"))
+	assert.False(t, ValidAddress("4539102834756194"))
+	assert.False(t, ValidAddress("4593102834756195"))
+	assert.False(t, ValidAddress("453910283475619"))
+	assert.False(t, ValidAddress("45391028347561a5"))
+
+	repo := new(MockRepository)
+	repo.On("FindByAddress", mock.Anything, mock.Anything).Return(nil, errors.ErrWalletNotFound)
+	svc := NewService(repo, nil, nil, logger.NewNop())
+	for i := 0; i < 50; i++ {
+		addr, err := svc.generateWalletNumber()
+		require.NoError(t, err)
+		assert.True(t, ValidAddress(addr), addr)
+	}
+}
+
+func TestQRCodeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockRepository)
+	users := new(MockUserRepository)
+	svc := NewService(repo, nil, users, logger.NewNop())
+
+	userID := uuid.New()
+	addr := "4539102834756195"
+	w := &domain.Wallet{ID: uuid.New(), UserID: userID, WalletAddress: &addr, Currency: domain.MWK, Status: domain.WalletStatusActive}
+	repo.On("FindByID", ctx, w.ID).Return(w, nil)
+	repo.On("FindByAddress", ctx, addr).Return(w, nil)
+	repo.On("FindByAddress", ctx, "4539102834756194").Return(nil, errors.ErrWalletNotFound)
+	users.On("FindByID", ctx, userID).Return(&domain.User{ID: userID, FirstName: "Chikondi", LastName: "Banda", City: "Blantyre", CountryCode: "MW"}, nil)
+
+	amount := decimal.RequireFromString("2500")
+	code, err := svc.QRCode(ctx, userID, w.ID, &amount, "INV 17")
+	require.NoError(t, err)
+	assert.Equal(t, addr, code.WalletAddress)
+
+	scanned, err := svc.DecodeQRCode(ctx, code.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, "Chikondi Banda", scanned.Name)
+	assert.Equal(t, "4539 1028 3475 6195", scanned.FormattedWalletAddress)
+	assert.Equal(t, domain.MWK, scanned.Currency)
+	require.NotNil(t, scanned.Amount)
+	assert.True(t, amount.Equal(*scanned.Amount))
+	assert.Equal(t, "INV 17", scanned.Reference)
+
+	// Only the owner gets a wallet's code, and only with sensible details
+	_, err = svc.QRCode(ctx, uuid.New(), w.ID, nil, "")
+	assert.ErrorIs(t, err, errors.ErrWalletNotFound)
+	bad := decimal.RequireFromString("1.005")
+	_, err = svc.QRCode(ctx, userID, w.ID, &bad, "")
+	assert.ErrorIs(t, err, ErrInvalidQRRequest)
+	_, err = svc.QRCode(ctx, userID, w.ID, nil, "order #1")
+	assert.ErrorIs(t, err, ErrInvalidQRRequest)
+
+	// A code naming a mistyped address, or in another currency, is refused
+	mistyped, err := emvqr.Encode(&emvqr.Payload{Address: "4539102834756194", Currency: "MWK"})
+	require.NoError(t, err)
+	_, err = svc.DecodeQRCode(ctx, mistyped)
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	dollars, err := emvqr.Encode(&emvqr.Payload{Address: addr, Currency: "USD"})
+	require.NoError(t, err)
+	_, err = svc.DecodeQRCode(ctx, dollars)
+	assert.ErrorIs(t, err, emvqr.ErrUnsupported)
+}
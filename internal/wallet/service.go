@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"kyd/internal/domain"
+	"kyd/pkg/clock"
 	"kyd/pkg/errors"
 	"kyd/pkg/logger"
 
@@ -25,6 +26,7 @@ type Service struct {
 	repo     Repository
 	txRepo   TransactionRepository
 	userRepo UserRepository
+	activity ActivityFeed
 	logger   logger.Logger
 }
 
@@ -37,11 +39,23 @@ func NewService(repo Repository, txRepo TransactionRepository, userRepo UserRepo
 	}
 }
 
+// WithActivity serves wallet history from the activity feed rather than by
+// joining each transaction to its parties at read time.
+func (s *Service) WithActivity(feed ActivityFeed) *Service {
+	s.activity = feed
+	return s
+}
+
 type DepositRequest struct {
 	WalletID uuid.UUID       `json:"wallet_id"`
 	Amount   decimal.Decimal `json:"amount" validate:"required,gt=0"`
 	SourceID string          `json:"source_id" validate:"required"`
 	Currency domain.Currency `json:"currency" validate:"required"`
+
+	// Set by internal callers (e.g. the on-chain deposit listener); never bound from requests.
+	Reference string          `json:"-"`
+	Channel   string          `json:"-"`
+	Metadata  domain.Metadata `json:"-"`
 }
 
 // Deposit adds funds to a wallet
@@ -73,6 +87,11 @@ func (s *Service) Deposit(ctx context.Context, req *DepositRequest) (*domain.Wal
 		return nil, errors.Wrap(err, "failed to update wallet balance")
 	}
 
+	reference := req.Reference
+	if reference == "" {
+		reference = fmt.Sprintf("DEP-%s", uuid.New().String()[:8])
+	}
+
 	// Create transaction record
 	tx := &domain.Transaction{
 		ID:               uuid.New(),
@@ -82,8 +101,10 @@ func (s *Service) Deposit(ctx context.Context, req *DepositRequest) (*domain.Wal
 		Currency:         req.Currency,
 		SenderWalletID:   nil, // External source
 		ReceiverWalletID: &wallet.ID,
-		Reference:        fmt.Sprintf("DEP-%s", uuid.New().String()[:8]),
+		Reference:        reference,
 		Description:      fmt.Sprintf("Deposit from %s", req.SourceID),
+		Channel:          req.Channel,
+		Metadata:         req.Metadata,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 	}
@@ -387,6 +408,9 @@ type LookupResponse struct {
 func (s *Service) LookupWallet(ctx context.Context, address string) (*LookupResponse, error) {
 	address = normalizeWalletAddress(address)
 	wallet, err := s.repo.FindByAddress(ctx, address)
+	if err == errors.ErrWalletNotFound && !ValidAddress(address) {
+		return nil, ErrInvalidAddress
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -437,6 +461,8 @@ type TransactionDetail struct {
 	ReceiverName         string `json:"receiver_name,omitempty"`
 	SenderWalletNumber   string `json:"sender_wallet_number,omitempty"`
 	ReceiverWalletNumber string `json:"receiver_wallet_number,omitempty"`
+	// CreatedAtLocal presents CreatedAt in the wallet owner's time zone.
+	CreatedAtLocal *clock.Stamp `json:"created_at_local,omitempty"`
 }
 
 func (s *Service) GetTransactionHistory(ctx context.Context, walletID, userID uuid.UUID, limit, offset int) ([]*TransactionDetail, int, error) {
@@ -448,7 +474,11 @@ func (s *Service) GetTransactionHistory(ctx context.Context, walletID, userID uu
 		return nil, 0, fmt.Errorf("unauthorized access to wallet")
 	}
 
-	return s.getTransactionHistoryInternal(ctx, walletID, limit, offset)
+	zone := clock.UTCName
+	if owner, err := s.userRepo.FindByID(ctx, userID); err == nil {
+		zone = owner.TimeZone
+	}
+	return s.getTransactionHistoryInternal(ctx, walletID, &zone, limit, offset)
 }
 
 func (s *Service) GetTransactionHistoryAdmin(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*TransactionDetail, int, error) {
@@ -456,10 +486,12 @@ func (s *Service) GetTransactionHistoryAdmin(ctx context.Context, walletID uuid.
 	if err != nil {
 		return nil, 0, err
 	}
-	return s.getTransactionHistoryInternal(ctx, walletID, limit, offset)
+	return s.getTransactionHistoryInternal(ctx, walletID, nil, limit, offset)
 }
 
-func (s *Service) getTransactionHistoryInternal(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*TransactionDetail, int, error) {
+// getTransactionHistoryInternal lists a wallet's transactions. When zone is
+// set, each carries its time in that zone for the owner's statement view.
+func (s *Service) getTransactionHistoryInternal(ctx context.Context, walletID uuid.UUID, zone *string, limit, offset int) ([]*TransactionDetail, int, error) {
 	txs, err := s.txRepo.FindByWalletID(ctx, walletID, limit, offset)
 	if err != nil {
 		return nil, 0, err
@@ -476,6 +508,10 @@ func (s *Service) getTransactionHistoryInternal(ctx context.Context, walletID uu
 	var details []*TransactionDetail
 	for _, tx := range txs {
 		detail := &TransactionDetail{Transaction: tx}
+		if zone != nil {
+			stamp := clock.In(tx.CreatedAt, *zone)
+			detail.CreatedAtLocal = &stamp
+		}
 
 		if sender, err := s.userRepo.FindByID(ctx, tx.SenderID); err == nil {
 			detail.SenderName = sender.FirstName + " " + sender.LastName
@@ -503,6 +539,66 @@ func (s *Service) getTransactionHistoryInternal(ctx context.Context, walletID uu
 	return details, total, nil
 }
 
+// ActivityItem is a wallet history entry with its time in the owner's zone.
+type ActivityItem struct {
+	domain.WalletActivity
+	OccurredAtLocal clock.Stamp `json:"occurred_at_local"`
+}
+
+// GetActivity returns the owner's history of the wallet from the activity
+// feed by cursor (see ActivityFeed.History) and the next page's cursor.
+func (s *Service) GetActivity(ctx context.Context, walletID, userID uuid.UUID, after *domain.TransactionCursor, limit int) ([]ActivityItem, *domain.TransactionCursor, error) {
+	zone, err := s.activityAccess(ctx, walletID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, next, err := s.activity.History(ctx, walletID, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return activityItems(rows, zone), next, nil
+}
+
+// GetActivityPage is GetActivity by offset, with the wallet's total.
+func (s *Service) GetActivityPage(ctx context.Context, walletID, userID uuid.UUID, limit, offset int) ([]ActivityItem, int, error) {
+	zone, err := s.activityAccess(ctx, walletID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, total, err := s.activity.HistoryPage(ctx, walletID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return activityItems(rows, zone), total, nil
+}
+
+// activityAccess checks userID owns the wallet and returns their time zone.
+func (s *Service) activityAccess(ctx context.Context, walletID, userID uuid.UUID) (string, error) {
+	if s.activity == nil {
+		return "", errors.New("wallet activity feed is not configured")
+	}
+	wallet, err := s.repo.FindByID(ctx, walletID)
+	if err != nil {
+		return "", err
+	}
+	if wallet.UserID != userID {
+		return "", fmt.Errorf("unauthorized access to wallet")
+	}
+	zone := clock.UTCName
+	if owner, err := s.userRepo.FindByID(ctx, userID); err == nil {
+		zone = owner.TimeZone
+	}
+	return zone, nil
+}
+
+func activityItems(rows []domain.WalletActivity, zone string) []ActivityItem {
+	items := make([]ActivityItem, len(rows))
+	for i, row := range rows {
+		items[i] = ActivityItem{WalletActivity: row, OccurredAtLocal: clock.In(row.OccurredAt, zone)}
+	}
+	return items
+}
+
 type Repository interface {
 	Create(ctx context.Context, wallet *domain.Wallet) error
 	Update(ctx context.Context, wallet *domain.Wallet) error
@@ -529,14 +625,23 @@ type UserRepository interface {
 	FindByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
 }
 
+// ActivityFeed reads the wallet activity feed. Satisfied by
+// activity.Projector.
+type ActivityFeed interface {
+	History(ctx context.Context, walletID uuid.UUID, after *domain.TransactionCursor, limit int) ([]domain.WalletActivity, *domain.TransactionCursor, error)
+	HistoryPage(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]domain.WalletActivity, int, error)
+}
+
 func (s *Service) generateWalletNumber() (string, error) {
-	// Generate a unique 16-digit number; retry on collision.
+	// Generate a unique 16-digit number ending in its check digit; retry on
+	// collision.
 	for i := 0; i < 10; i++ {
-		n, err := rand.Int(rand.Reader, big.NewInt(10000000000000000))
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000000000000))
 		if err != nil {
 			return "", err
 		}
-		candidate := fmt.Sprintf("%016d", n)
+		body := fmt.Sprintf("%015d", n)
+		candidate := body + string(addressCheckDigit(body))
 
 		_, err = s.repo.FindByAddress(context.Background(), candidate)
 		if err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // --- Mocks ---
@@ -173,6 +174,7 @@ func TestGetTransactionHistory(t *testing.T) {
 	// Mock Wallet Existence Check (initial check)
 	userID := uuid.New()
 	mockRepo.On("FindByID", ctx, walletID).Return(&domain.Wallet{ID: walletID, UserID: userID}, nil)
+	mockUserRepo.On("FindByID", ctx, userID).Return(&domain.User{ID: userID, TimeZone: "Africa/Blantyre"}, nil)
 
 	// Mock Transaction Fetch
 	expectedTxs := []*domain.Transaction{
@@ -210,12 +212,53 @@ func TestGetTransactionHistory(t *testing.T) {
 	assert.Equal(t, "Jane Doe", txs[0].ReceiverName)
 	assert.Equal(t, senderAddr, txs[0].SenderWalletNumber)
 	assert.Equal(t, receiverAddr, txs[0].ReceiverWalletNumber)
+	require.NotNil(t, txs[0].CreatedAtLocal)
+	assert.Equal(t, "Africa/Blantyre", txs[0].CreatedAtLocal.TimeZone)
 
 	mockRepo.AssertExpectations(t)
 	mockTxRepo.AssertExpectations(t)
 	mockUserRepo.AssertExpectations(t)
 }
 
+type stubActivityFeed struct {
+	rows []domain.WalletActivity
+}
+
+func (f *stubActivityFeed) History(ctx context.Context, walletID uuid.UUID, after *domain.TransactionCursor, limit int) ([]domain.WalletActivity, *domain.TransactionCursor, error) {
+	return f.rows, f.rows[len(f.rows)-1].Cursor(), nil
+}
+
+func (f *stubActivityFeed) HistoryPage(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]domain.WalletActivity, int, error) {
+	return f.rows, len(f.rows), nil
+}
+
+func TestGetActivity(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockUserRepo := new(MockUserRepository)
+	ctx := context.Background()
+
+	walletID, ownerID := uuid.New(), uuid.New()
+	occurred := time.Date(2026, 3, 1, 22, 30, 0, 0, time.UTC)
+	feed := &stubActivityFeed{rows: []domain.WalletActivity{
+		{WalletID: walletID, TransactionID: uuid.New(), Direction: domain.ActivityCredit, CounterpartyName: "Jane Doe", OccurredAt: occurred},
+	}}
+	service := NewService(mockRepo, new(MockTransactionRepository), mockUserRepo, logger.NewNop()).WithActivity(feed)
+
+	mockRepo.On("FindByID", ctx, walletID).Return(&domain.Wallet{ID: walletID, UserID: ownerID}, nil)
+	mockUserRepo.On("FindByID", ctx, ownerID).Return(&domain.User{ID: ownerID, TimeZone: "Africa/Blantyre"}, nil)
+
+	items, next, err := service.GetActivity(ctx, walletID, ownerID, nil, 1)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Jane Doe", items[0].CounterpartyName)
+	assert.Equal(t, "Africa/Blantyre", items[0].OccurredAtLocal.TimeZone)
+	require.NotNil(t, next)
+	assert.Equal(t, items[0].TransactionID, next.ID)
+
+	_, _, err = service.GetActivity(ctx, walletID, uuid.New(), nil, 1)
+	assert.Error(t, err, "only the owner reads the history")
+}
+
 func TestGetUserWallets(t *testing.T) {
 	mockRepo := new(MockRepository)
 	mockTxRepo := new(MockTransactionRepository)
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// Start sends due deliveries every poll interval, and as soon as new ones
+// are queued, until Stop.
+func (s *Service) Start() {
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(s.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.DeliverDue(context.Background())
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Stop ends the worker after the batch in flight.
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.done.Wait()
+}
+
+// kick wakes the worker without blocking.
+func (s *Service) kick() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverDue sends the deliveries that are due, one batch at a time, and
+// returns how many it attempted.
+func (s *Service) DeliverDue(ctx context.Context) int {
+	lease := s.cfg.Timeout * 2
+	attempted := 0
+	for {
+		batch, err := s.repo.ClaimDue(ctx, s.now().UTC(), lease, claimBatchSize)
+		if err != nil {
+			s.logger.Error("Failed to claim webhook deliveries", map[string]interface{}{"error": err.Error()})
+			return attempted
+		}
+		endpoints := make(map[uuid.UUID]*domain.WebhookEndpoint)
+		for i := range batch {
+			s.deliver(ctx, &batch[i], endpoints)
+		}
+		attempted += len(batch)
+		if len(batch) < claimBatchSize {
+			return attempted
+		}
+		select {
+		case <-s.stop:
+			return attempted
+		default:
+		}
+	}
+}
+
+// deliver makes one attempt at d and records the outcome. endpoints caches
+// the endpoints looked up in the current batch.
+func (s *Service) deliver(ctx context.Context, d *domain.WebhookDelivery, endpoints map[uuid.UUID]*domain.WebhookEndpoint) {
+	e, ok := endpoints[d.EndpointID]
+	if !ok {
+		var err error
+		if e, err = s.repo.GetEndpoint(ctx, d.EndpointID); err != nil {
+			s.logger.Error("Failed to load webhook endpoint", map[string]interface{}{"endpoint_id": d.EndpointID, "error": err.Error()})
+			return
+		}
+		endpoints[d.EndpointID] = e
+	}
+
+	now := s.now().UTC()
+	d.UpdatedAt = now
+	if e == nil || !e.Active {
+		d.Status = domain.WebhookDeliveryFailed
+		d.NextAttemptAt = nil
+		d.LastError = "endpoint disabled"
+		s.save(ctx, d)
+		return
+	}
+
+	d.Attempts++
+	status, body, elapsed, _, err := s.send(ctx, e, d, false)
+	d.ResponseStatus = status
+	d.ResponseBody = body
+	d.DurationMS = elapsed.Milliseconds()
+	d.UpdatedAt = s.now().UTC()
+	if err == nil {
+		delivered := d.UpdatedAt
+		d.Status = domain.WebhookDeliverySucceeded
+		d.DeliveredAt = &delivered
+		d.NextAttemptAt = nil
+		d.LastError = ""
+		s.save(ctx, d)
+		return
+	}
+
+	d.LastError = err.Error()
+	if d.Attempts >= s.cfg.MaxAttempts {
+		d.Status = domain.WebhookDeliveryFailed
+		d.NextAttemptAt = nil
+		s.logger.Warn("Webhook delivery gave up", map[string]interface{}{
+			"delivery_id": d.ID,
+			"endpoint_id": d.EndpointID,
+			"event":       d.EventType,
+			"attempts":    d.Attempts,
+			"error":       d.LastError,
+		})
+	} else {
+		next := d.UpdatedAt.Add(retryDelay(d.Attempts))
+		d.NextAttemptAt = &next
+	}
+	s.save(ctx, d)
+}
+
+// send posts the signed payload, marked as a test event when test is set,
+// and returns the response and the signature sent. Any response other
+// than 2xx is an error.
+func (s *Service) send(ctx context.Context, e *domain.WebhookEndpoint, d *domain.WebhookDelivery, test bool) (int, string, time.Duration, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "KYD-Webhooks/1.0")
+	now := s.now()
+	sig := SignAll(e.SigningSecrets(now), now, d.Payload)
+	req.Header.Set(HeaderSignature, sig)
+	req.Header.Set(HeaderEvent, string(d.EventType))
+	req.Header.Set(HeaderEventID, d.EventID.String())
+	req.Header.Set(HeaderDelivery, d.ID.String())
+	if d.ReplayOf != nil {
+		req.Header.Set(HeaderReplayOf, d.ReplayOf.String())
+	}
+	if test {
+		req.Header.Set(HeaderTest, "true")
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, "", elapsed, sig, err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	// The excerpt is stored as text, which cannot hold NULs or invalid UTF-8.
+	body := strings.ToValidUTF8(strings.ReplaceAll(string(raw), "\x00", ""), "")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, body, elapsed, sig, fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, body, elapsed, sig, nil
+}
+
+func (s *Service) save(ctx context.Context, d *domain.WebhookDelivery) {
+	if err := s.repo.UpdateDelivery(ctx, d); err != nil {
+		s.logger.Error("Failed to record webhook delivery", map[string]interface{}{"delivery_id": d.ID, "error": err.Error()})
+	}
+}
+
+// retryDelay is the wait after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > len(retrySchedule) {
+		return retrySchedule[len(retrySchedule)-1]
+	}
+	return retrySchedule[attempts-1]
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// eventTypes maps transaction milestones to the webhook events they fire.
+var eventTypes = map[domain.TransactionEventType]domain.WebhookEventType{
+	domain.TransactionEventInitiated:     domain.WebhookTransactionCreated,
+	domain.TransactionEventHeldForReview: domain.WebhookTransactionPendingApproval,
+	domain.TransactionEventDelivered:     domain.WebhookTransactionCompleted,
+	domain.TransactionEventFailed:        domain.WebhookTransactionFailed,
+	domain.TransactionEventCancelled:     domain.WebhookTransactionCancelled,
+	domain.TransactionEventReversed:      domain.WebhookTransactionReversed,
+	domain.TransactionEventConfirmed:     domain.WebhookSettlementConfirmed,
+}
+
+func knownEvent(t domain.WebhookEventType) bool {
+	if t == domain.WebhookReportReady || t == domain.WebhookStatementReady {
+		return true
+	}
+	for _, known := range eventTypes {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// EventStore is the transaction event stream the payment and settlement
+// services append to.
+type EventStore interface {
+	Append(ctx context.Context, e *domain.TransactionEvent) error
+	ListByTransaction(ctx context.Context, txID uuid.UUID) ([]*domain.TransactionEvent, error)
+}
+
+// EventStream is an EventStore that also queues webhooks for each event
+// appended.
+type EventStream struct {
+	EventStore
+	webhooks *Service
+}
+
+// Stream wraps store so that events appended to it fire webhooks.
+func (s *Service) Stream(store EventStore) *EventStream {
+	return &EventStream{EventStore: store, webhooks: s}
+}
+
+// Append stores e and then queues its webhooks in the background, so that
+// a slow or failing webhook store never holds up a payment.
+func (st *EventStream) Append(ctx context.Context, e *domain.TransactionEvent) error {
+	if err := st.EventStore.Append(ctx, e); err != nil {
+		return err
+	}
+	if _, ok := eventTypes[e.Type]; ok {
+		event := *e
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := st.webhooks.Publish(ctx, &event); err != nil {
+				st.webhooks.logger.Error("Failed to queue webhooks", map[string]interface{}{
+					"transaction_id": event.TransactionID,
+					"event":          event.Type,
+					"error":          err.Error(),
+				})
+			}
+		}()
+	}
+	return nil
+}
+
+// Payload is the body of every transaction event delivery.
+type Payload struct {
+	ID        uuid.UUID               `json:"id"`
+	Type      domain.WebhookEventType `json:"type"`
+	CreatedAt time.Time               `json:"created_at"`
+	Data      PayloadData             `json:"data"`
+}
+
+type PayloadData struct {
+	Transaction TransactionSummary `json:"transaction"`
+	Details     domain.Metadata    `json:"details,omitempty"`
+}
+
+// TransactionSummary is what endpoints learn about a transaction.
+type TransactionSummary struct {
+	ID                uuid.UUID                `json:"id"`
+	Reference         string                   `json:"reference"`
+	ReferenceNumber   string                   `json:"reference_number,omitempty"`
+	Status            domain.TransactionStatus `json:"status"`
+	SenderID          uuid.UUID                `json:"sender_id"`
+	ReceiverID        uuid.UUID                `json:"receiver_id"`
+	Amount            decimal.Decimal          `json:"amount"`
+	Currency          domain.Currency          `json:"currency"`
+	ConvertedAmount   decimal.Decimal          `json:"converted_amount"`
+	ConvertedCurrency domain.Currency          `json:"converted_currency"`
+	FeeAmount         decimal.Decimal          `json:"fee_amount"`
+	SettlementID      *uuid.UUID               `json:"settlement_id,omitempty"`
+	CreatedAt         time.Time                `json:"created_at"`
+	CompletedAt       *time.Time               `json:"completed_at,omitempty"`
+}
+
+// Publish queues a delivery of e to every active endpoint subscribed to it:
+// the platform's and those of the transaction's parties.
+func (s *Service) Publish(ctx context.Context, e *domain.TransactionEvent) error {
+	eventType, ok := eventTypes[e.Type]
+	if !ok {
+		return nil
+	}
+	tx, err := s.txs.FindByID(ctx, e.TransactionID)
+	if err != nil {
+		return err
+	}
+	endpoints, err := s.repo.ListActiveEndpoints(ctx, []uuid.UUID{tx.SenderID, tx.ReceiverID})
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        e.ID,
+		Type:      eventType,
+		CreatedAt: e.CreatedAt.UTC(),
+		Data: PayloadData{
+			Transaction: summarize(tx),
+			Details:     e.Details,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return s.queue(ctx, endpoints, e.ID, eventType, body)
+}
+
+// queue queues body for each of endpoints subscribed to eventType and wakes
+// the worker.
+func (s *Service) queue(ctx context.Context, endpoints []domain.WebhookEndpoint, eventID uuid.UUID, eventType domain.WebhookEventType, body []byte) error {
+	now := s.now().UTC()
+	var deliveries []*domain.WebhookDelivery
+	for i := range endpoints {
+		if !endpoints[i].Subscribes(eventType) {
+			continue
+		}
+		deliveries = append(deliveries, &domain.WebhookDelivery{
+			ID:            uuid.New(),
+			EndpointID:    endpoints[i].ID,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       body,
+			Status:        domain.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := s.repo.CreateDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+	s.kick()
+	return nil
+}
+
+func summarize(tx *domain.Transaction) TransactionSummary {
+	return TransactionSummary{
+		ID:                tx.ID,
+		Reference:         tx.Reference,
+		ReferenceNumber:   tx.ReferenceNumber,
+		Status:            tx.Status,
+		SenderID:          tx.SenderID,
+		ReceiverID:        tx.ReceiverID,
+		Amount:            tx.Amount,
+		Currency:          tx.Currency,
+		ConvertedAmount:   tx.ConvertedAmount,
+		ConvertedCurrency: tx.ConvertedCurrency,
+		FeeAmount:         tx.FeeAmount,
+		SettlementID:      tx.SettlementID,
+		CreatedAt:         tx.CreatedAt,
+		CompletedAt:       tx.CompletedAt,
+	}
+}
@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// maxReplayBatch caps how many deliveries one range replay may queue.
+const maxReplayBatch = 500
+
+// ReplayRequest selects the deliveries of a range replay: those queued in
+// [From, To), optionally only with the given status or event type.
+type ReplayRequest struct {
+	From      time.Time                    `json:"from"`
+	To        time.Time                    `json:"to"`
+	Status    domain.WebhookDeliveryStatus `json:"status"`
+	EventType domain.WebhookEventType      `json:"event_type"`
+}
+
+// GetDelivery returns one delivery of an endpoint visible to owner; nil
+// sees all.
+func (s *Service) GetDelivery(ctx context.Context, owner *uuid.UUID, endpointID, id uuid.UUID) (*domain.WebhookDelivery, error) {
+	if _, err := s.GetEndpoint(ctx, owner, endpointID); err != nil {
+		return nil, err
+	}
+	d, err := s.repo.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.EndpointID != endpointID {
+		return nil, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+// Replay queues the event of a finished delivery to be sent again, with the
+// payload it was first sent with, and returns the new delivery.
+func (s *Service) Replay(ctx context.Context, owner *uuid.UUID, endpointID, deliveryID uuid.UUID) (*domain.WebhookDelivery, error) {
+	e, err := s.replayableEndpoint(ctx, owner, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.GetDelivery(ctx, owner, e.ID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status == domain.WebhookDeliveryPending {
+		return nil, fmt.Errorf("%w: the delivery is still being attempted", ErrInvalidReplay)
+	}
+	replays, err := s.queueReplays(ctx, []domain.WebhookDelivery{*d})
+	if err != nil {
+		return nil, err
+	}
+	return replays[0], nil
+}
+
+// ReplayRange queues again every finished original delivery of an endpoint
+// that req selects, and returns the new deliveries. It refuses ranges of
+// more than maxReplayBatch deliveries rather than replaying part of one.
+func (s *Service) ReplayRange(ctx context.Context, owner *uuid.UUID, endpointID uuid.UUID, req ReplayRequest) ([]*domain.WebhookDelivery, error) {
+	if req.From.IsZero() || req.To.IsZero() || !req.From.Before(req.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidReplay)
+	}
+	if req.Status == domain.WebhookDeliveryPending {
+		return nil, fmt.Errorf("%w: pending deliveries are still being attempted", ErrInvalidReplay)
+	}
+	if req.EventType != "" && !knownEvent(req.EventType) {
+		return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidReplay, req.EventType)
+	}
+	e, err := s.replayableEndpoint(ctx, owner, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	from, to := req.From, req.To
+	found, err := s.repo.ListDeliveries(ctx, e.ID, DeliveryFilter{
+		Status:        req.Status,
+		EventType:     req.EventType,
+		From:          &from,
+		To:            &to,
+		OriginalsOnly: true,
+		Limit:         maxReplayBatch + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) > maxReplayBatch {
+		return nil, ErrReplayTooLarge
+	}
+	finished := found[:0]
+	for _, d := range found {
+		if d.Status != domain.WebhookDeliveryPending {
+			finished = append(finished, d)
+		}
+	}
+	if len(finished) == 0 {
+		return []*domain.WebhookDelivery{}, nil
+	}
+	return s.queueReplays(ctx, finished)
+}
+
+// replayableEndpoint returns the endpoint if owner can see it and it is
+// active; a replay to a disabled endpoint would fail straight away.
+func (s *Service) replayableEndpoint(ctx context.Context, owner *uuid.UUID, id uuid.UUID) (*domain.WebhookEndpoint, error) {
+	e, err := s.GetEndpoint(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if !e.Active {
+		return nil, fmt.Errorf("%w: the endpoint is disabled", ErrInvalidReplay)
+	}
+	return e, nil
+}
+
+// queueReplays queues a replay of each delivery and wakes the worker.
+// Replays of a replay point at the original delivery.
+func (s *Service) queueReplays(ctx context.Context, originals []domain.WebhookDelivery) ([]*domain.WebhookDelivery, error) {
+	now := s.now().UTC()
+	replays := make([]*domain.WebhookDelivery, 0, len(originals))
+	for _, o := range originals {
+		of := o.ID
+		if o.ReplayOf != nil {
+			of = *o.ReplayOf
+		}
+		next := now
+		replays = append(replays, &domain.WebhookDelivery{
+			ID:            uuid.New(),
+			EndpointID:    o.EndpointID,
+			EventID:       o.EventID,
+			EventType:     o.EventType,
+			ReplayOf:      &of,
+			Payload:       o.Payload,
+			Status:        domain.WebhookDeliveryPending,
+			NextAttemptAt: &next,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if err := s.repo.CreateDeliveries(ctx, replays); err != nil {
+		return nil, err
+	}
+	s.kick()
+	return replays, nil
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// ReportPayload is the body of a report.ready delivery.
+type ReportPayload struct {
+	ID        uuid.UUID               `json:"id"`
+	Type      domain.WebhookEventType `json:"type"`
+	CreatedAt time.Time               `json:"created_at"`
+	Data      ReportData              `json:"data"`
+}
+
+type ReportData struct {
+	Report ReportSummary `json:"report"`
+}
+
+// ReportSummary describes a finished partner report and where to fetch it.
+type ReportSummary struct {
+	ID                uuid.UUID         `json:"id"`
+	Kind              domain.ExportKind `json:"kind"`
+	From              *time.Time        `json:"from,omitempty"`
+	To                *time.Time        `json:"to,omitempty"`
+	Currency          string            `json:"currency,omitempty"`
+	Rows              int               `json:"rows"`
+	FileSize          int64             `json:"file_size"`
+	DownloadURL       string            `json:"download_url"`
+	DownloadExpiresAt time.Time         `json:"download_expires_at"`
+}
+
+// PublishReport queues a report.ready delivery to the report owner's
+// subscribed endpoints. Platform endpoints are left out: a report belongs
+// to its owner. The event ID is the report's, so it is sent at most once.
+func (s *Service) PublishReport(ctx context.Context, j *domain.ExportJob, downloadURL string, expiresAt time.Time) error {
+	if j.OwnerID == nil {
+		return nil
+	}
+	endpoints, err := s.repo.ListEndpoints(ctx, j.OwnerID)
+	if err != nil {
+		return err
+	}
+	active := endpoints[:0]
+	for _, e := range endpoints {
+		if e.Active {
+			active = append(active, e)
+		}
+	}
+	created := s.now().UTC()
+	if j.CompletedAt != nil {
+		created = j.CompletedAt.UTC()
+	}
+	body, err := json.Marshal(ReportPayload{
+		ID:        j.ID,
+		Type:      domain.WebhookReportReady,
+		CreatedAt: created,
+		Data: ReportData{Report: ReportSummary{
+			ID:                j.ID,
+			Kind:              j.Kind,
+			From:              j.Filters.From,
+			To:                j.Filters.To,
+			Currency:          j.Filters.Currency,
+			Rows:              j.TotalRows,
+			FileSize:          j.FileSize,
+			DownloadURL:       downloadURL,
+			DownloadExpiresAt: expiresAt.UTC(),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	return s.queue(ctx, active, j.ID, domain.WebhookReportReady, body)
+}
@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// maxSamplePayload bounds the payloads the signature helper signs.
+const maxSamplePayload = 64 << 10
+
+var (
+	// ErrInvalidSample is returned for a signature check the helper cannot
+	// run, such as one without a payload.
+	ErrInvalidSample = errors.New("invalid signature check")
+	// ErrInvalidTestEvent is returned for a test event of an unknown type.
+	ErrInvalidTestEvent = errors.New("invalid test event")
+)
+
+// SignatureRequest asks for the signature an endpoint's deliveries of
+// Payload carry. Payload is the raw request body, byte for byte: the
+// signature covers it exactly, whitespace included.
+type SignatureRequest struct {
+	Payload string `json:"payload"`
+	// Timestamp is the Unix time to sign at; zero is now.
+	Timestamp int64 `json:"timestamp"`
+	// Signature, when set, is a KYD-Signature header to check against the
+	// payload, as the receiver would.
+	Signature string `json:"signature"`
+}
+
+// SignatureCheck is what the helper worked out. Secrets are never shown.
+type SignatureCheck struct {
+	Timestamp int64 `json:"timestamp"`
+	// SignedContent is the string the HMAC is taken over: "<t>.<payload>".
+	SignedContent string `json:"signed_content"`
+	// Expected is the KYD-Signature header a delivery of the payload at
+	// Timestamp carries, with one v1 per secret in use.
+	Expected string `json:"expected_signature"`
+	// Valid and Problem are set when a signature was given to check.
+	Valid   *bool  `json:"valid,omitempty"`
+	Problem string `json:"problem,omitempty"`
+}
+
+// Signature signs a sample payload with an endpoint's secrets, so that a
+// receiver can compare it with what their own verification computes, and
+// checks a signature they computed or received when one is given.
+func (s *Service) Signature(ctx context.Context, owner *uuid.UUID, endpointID uuid.UUID, req SignatureRequest) (*SignatureCheck, error) {
+	if req.Payload == "" {
+		return nil, fmt.Errorf("%w: payload is required", ErrInvalidSample)
+	}
+	if len(req.Payload) > maxSamplePayload {
+		return nil, fmt.Errorf("%w: payload is larger than %d bytes", ErrInvalidSample, maxSamplePayload)
+	}
+	e, err := s.GetEndpoint(ctx, owner, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	at := now
+	if req.Timestamp != 0 {
+		at = time.Unix(req.Timestamp, 0)
+	}
+	body := []byte(req.Payload)
+	secrets := e.SigningSecrets(now)
+	check := &SignatureCheck{
+		Timestamp:     at.Unix(),
+		SignedContent: strconv.FormatInt(at.Unix(), 10) + "." + req.Payload,
+		Expected:      SignAll(secrets, at, body),
+	}
+	if req.Signature == "" {
+		return check, nil
+	}
+
+	valid := false
+	var verr error
+	for _, secret := range secrets {
+		if verr = Verify(secret, req.Signature, body, now, DefaultTolerance); verr == nil {
+			valid = true
+			break
+		}
+	}
+	check.Valid = &valid
+	switch {
+	case valid:
+	case !strings.Contains(req.Signature, "t=") || !strings.Contains(req.Signature, "v1="):
+		check.Problem = "the header must look like t=<unix seconds>,v1=<hex HMAC-SHA256>"
+	case errors.Is(verr, ErrSignatureExpired):
+		check.Problem = fmt.Sprintf("the timestamp is more than %s from now; receivers should reject it as a possible replay", DefaultTolerance)
+	default:
+		check.Problem = "no v1 signature matches the payload: check that the secret is current and that the raw body is signed, not re-encoded JSON"
+	}
+	return check, nil
+}
+
+// TestResult is the outcome of sending a test event.
+type TestResult struct {
+	EventID        uuid.UUID               `json:"event_id"`
+	EventType      domain.WebhookEventType `json:"event_type"`
+	URL            string                  `json:"url"`
+	Payload        domain.JSONPayload      `json:"payload"`
+	Signature      string                  `json:"signature"`
+	Succeeded      bool                    `json:"succeeded"`
+	ResponseStatus int                     `json:"response_status,omitempty"`
+	ResponseBody   string                  `json:"response_body,omitempty"`
+	DurationMS     int64                   `json:"duration_ms"`
+	Error          string                  `json:"error,omitempty"`
+}
+
+// SendTest sends an endpoint a sample event of eventType (transaction.created
+// when empty) straight away and reports how it answered. The event is made
+// up, carries the KYD-Test header, and is neither logged nor retried, so
+// receivers can be debugged without moving money. Disabled endpoints and
+// event types the endpoint does not subscribe to can be tested too.
+func (s *Service) SendTest(ctx context.Context, owner *uuid.UUID, endpointID uuid.UUID, eventType domain.WebhookEventType) (*TestResult, error) {
+	if eventType == "" {
+		eventType = domain.WebhookTransactionCreated
+	}
+	if !knownEvent(eventType) {
+		return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidTestEvent, eventType)
+	}
+	e, err := s.GetEndpoint(ctx, owner, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	d := &domain.WebhookDelivery{ID: uuid.New(), EndpointID: e.ID, EventID: uuid.New(), EventType: eventType, CreatedAt: now}
+	if d.Payload, err = samplePayload(d.EventID, eventType, e.OwnerID, now); err != nil {
+		return nil, err
+	}
+
+	status, body, elapsed, sig, err := s.send(ctx, e, d, true)
+	res := &TestResult{
+		EventID:        d.EventID,
+		EventType:      eventType,
+		URL:            e.URL,
+		Payload:        d.Payload,
+		Signature:      sig,
+		Succeeded:      err == nil,
+		ResponseStatus: status,
+		ResponseBody:   body,
+		DurationMS:     elapsed.Milliseconds(),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res, nil
+}
+
+// samplePayload is a made-up event of eventType shaped as real deliveries
+// are, about a transaction, report or statement of owner.
+func samplePayload(id uuid.UUID, eventType domain.WebhookEventType, owner *uuid.UUID, now time.Time) ([]byte, error) {
+	party := uuid.New()
+	if owner != nil {
+		party = *owner
+	}
+	switch eventType {
+	case domain.WebhookReportReady:
+		from := now.AddDate(0, 0, -7).Truncate(24 * time.Hour)
+		to := now.Truncate(24 * time.Hour)
+		return json.Marshal(ReportPayload{ID: id, Type: eventType, CreatedAt: now, Data: ReportData{Report: ReportSummary{
+			ID:                id,
+			Kind:              domain.ExportKindTransactions,
+			From:              &from,
+			To:                &to,
+			Currency:          "MWK",
+			DownloadURL:       "https://example.com/kyd-test-report.csv",
+			DownloadExpiresAt: now.Add(time.Hour),
+		}}})
+	case domain.WebhookStatementReady:
+		content := []byte("date,reference,description,amount,currency,balance\n")
+		sum := sha256.Sum256(content)
+		return json.Marshal(StatementPayload{ID: id, Type: eventType, CreatedAt: now, Data: StatementData{Statement: StatementFile{
+			ID:       id,
+			WalletID: uuid.New(),
+			Period:   now.AddDate(0, -1, 0).Format("2006-01"),
+			Format:   domain.StatementFormatCSV,
+			FileName: "kyd-test-statement.csv",
+			FileSize: int64(len(content)),
+			SHA256:   hex.EncodeToString(sum[:]),
+			Content:  content,
+		}}})
+	}
+
+	tx := TransactionSummary{
+		ID:                uuid.New(),
+		Reference:         "KYD-TEST-" + now.Format("20060102150405"),
+		Status:            sampleStatuses[eventType],
+		SenderID:          party,
+		ReceiverID:        uuid.New(),
+		Amount:            decimal.NewFromInt(10000),
+		Currency:          "MWK",
+		ConvertedAmount:   decimal.NewFromInt(10000),
+		ConvertedCurrency: "MWK",
+		FeeAmount:         decimal.NewFromInt(150),
+		CreatedAt:         now,
+	}
+	if tx.Status == domain.TransactionStatusCompleted {
+		tx.CompletedAt = &now
+	}
+	return json.Marshal(Payload{ID: id, Type: eventType, CreatedAt: now, Data: PayloadData{Transaction: tx}})
+}
+
+// sampleStatuses is the status a transaction has when each event fires.
+var sampleStatuses = map[domain.WebhookEventType]domain.TransactionStatus{
+	domain.WebhookTransactionCreated:         domain.TransactionStatusPending,
+	domain.WebhookTransactionPendingApproval: domain.TransactionStatusPendingApproval,
+	domain.WebhookTransactionCompleted:       domain.TransactionStatusCompleted,
+	domain.WebhookTransactionFailed:          domain.TransactionStatusFailed,
+	domain.WebhookTransactionCancelled:       domain.TransactionStatusCancelled,
+	domain.WebhookTransactionReversed:        domain.TransactionStatusReversed,
+	domain.WebhookSettlementConfirmed:        domain.TransactionStatusCompleted,
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	now := start
+	owner := uuid.New()
+	e := endpoint(&owner, "https://example.com/hook")
+	payload := `{"id":"evt_1", "type":"transaction.completed"}`
+	s, repo, _ := newService(&now, 3)
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+
+	check, err := s.Signature(ctx, &owner, e.ID, SignatureRequest{Payload: payload, Timestamp: 1700000000})
+	require.NoError(t, err)
+	assert.Equal(t, "1700000000."+payload, check.SignedContent)
+	assert.Equal(t, Sign(e.Secret, time.Unix(1700000000, 0), []byte(payload)), check.Expected)
+	assert.Nil(t, check.Valid, "nothing to check")
+	assert.NotContains(t, check.Expected, e.Secret)
+
+	good := Sign(e.Secret, now, []byte(payload))
+	check, err = s.Signature(ctx, &owner, e.ID, SignatureRequest{Payload: payload, Signature: good})
+	require.NoError(t, err)
+	require.NotNil(t, check.Valid)
+	assert.True(t, *check.Valid)
+	assert.Equal(t, good, check.Expected)
+
+	for name, sig := range map[string]string{
+		"re-encoded body": Sign(e.Secret, now, []byte(`{"id":"evt_1","type":"transaction.completed"}`)),
+		"old timestamp":   Sign(e.Secret, now.Add(-time.Hour), []byte(payload)),
+		"malformed":       "sha256=abc",
+	} {
+		check, err = s.Signature(ctx, &owner, e.ID, SignatureRequest{Payload: payload, Signature: sig})
+		require.NoError(t, err, name)
+		assert.False(t, *check.Valid, name)
+		assert.NotEmpty(t, check.Problem, name)
+	}
+
+	_, err = s.Signature(ctx, &owner, e.ID, SignatureRequest{})
+	assert.ErrorIs(t, err, ErrInvalidSample)
+	other := uuid.New()
+	_, err = s.Signature(ctx, &other, e.ID, SignatureRequest{Payload: payload})
+	assert.ErrorIs(t, err, ErrEndpointNotFound)
+
+	t.Run("while a rotated-out secret is honoured both are signed with", func(t *testing.T) {
+		rotated := *e
+		expires := now.Add(time.Hour)
+		rotated.Secret, _ = newSecret()
+		rotated.PreviousSecret, rotated.PreviousSecretExpiresAt = e.Secret, &expires
+		s, repo, _ := newService(&now, 3)
+		repo.On("GetEndpoint", ctx, e.ID).Return(&rotated, nil).Once()
+
+		check, err := s.Signature(ctx, &owner, e.ID, SignatureRequest{Payload: payload, Signature: good})
+		require.NoError(t, err)
+		assert.True(t, *check.Valid, "the old secret still verifies")
+		assert.Equal(t, 2, strings.Count(check.Expected, "v1="))
+	})
+}
+
+func TestSendTest(t *testing.T) {
+	now := start
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	owner := uuid.New()
+	e := endpoint(&owner, srv.URL, string(domain.WebhookTransactionCreated))
+	s, repo, _ := newService(&now, 3)
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+
+	res, err := s.SendTest(ctx, &owner, e.ID, domain.WebhookTransactionCompleted)
+	require.NoError(t, err)
+	assert.True(t, res.Succeeded, "sent though the endpoint does not subscribe to it")
+	assert.Equal(t, "ok", res.ResponseBody)
+	require.Len(t, rec.requests, 1)
+	received := rec.requests[0]
+	assert.Equal(t, "true", received.Header.Get(HeaderTest))
+	assert.Equal(t, string(domain.WebhookTransactionCompleted), received.Header.Get(HeaderEvent))
+	assert.Equal(t, res.Signature, received.Header.Get(HeaderSignature))
+	assert.NoError(t, Verify(e.Secret, received.Header.Get(HeaderSignature), rec.bodies[0], now, DefaultTolerance))
+
+	var p Payload
+	require.NoError(t, json.Unmarshal(rec.bodies[0], &p))
+	assert.Equal(t, res.EventID, p.ID)
+	assert.Equal(t, domain.TransactionStatusCompleted, p.Data.Transaction.Status)
+	assert.Equal(t, owner, p.Data.Transaction.SenderID)
+	assert.True(t, strings.HasPrefix(p.Data.Transaction.Reference, "KYD-TEST-"))
+
+	rec.status = http.StatusInternalServerError
+	res, err = s.SendTest(ctx, &owner, e.ID, domain.WebhookStatementReady)
+	require.NoError(t, err, "a failing receiver is the answer, not an error")
+	assert.False(t, res.Succeeded)
+	assert.Equal(t, http.StatusInternalServerError, res.ResponseStatus)
+	assert.Equal(t, "endpoint returned "+strconv.Itoa(http.StatusInternalServerError), res.Error)
+	var sp StatementPayload
+	require.NoError(t, json.Unmarshal(rec.bodies[1], &sp))
+	assert.Equal(t, domain.WebhookStatementReady, sp.Type)
+	assert.NotEmpty(t, sp.Data.Statement.Content)
+
+	_, err = s.SendTest(ctx, &owner, e.ID, "transaction.exploded")
+	assert.ErrorIs(t, err, ErrInvalidTestEvent)
+	other := uuid.New()
+	_, err = s.SendTest(ctx, &other, e.ID, "")
+	assert.ErrorIs(t, err, ErrEndpointNotFound)
+
+	repo.AssertNotCalled(t, "CreateDeliveries", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "UpdateDelivery", mock.Anything, mock.Anything)
+}
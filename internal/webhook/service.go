@@ -0,0 +1,334 @@
+// Package webhook sends signed HTTP callbacks to registered endpoints when
+// transactions reach lifecycle milestones, retrying failed deliveries and
+// keeping a log of each attempt.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrInvalidEndpoint  = errors.New("invalid webhook endpoint")
+	ErrTooManyEndpoints = errors.New("webhook endpoint limit reached")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidReplay    = errors.New("invalid webhook replay")
+	ErrReplayTooLarge   = errors.New("too many deliveries to replay at once")
+	ErrInvalidRotation  = errors.New("invalid secret rotation")
+)
+
+// Repository stores endpoints and deliveries.
+type Repository interface {
+	CreateEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error
+	UpdateEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) (bool, error)
+	// GetEndpoint returns the endpoint, or nil.
+	GetEndpoint(ctx context.Context, id uuid.UUID) (*domain.WebhookEndpoint, error)
+	// ListEndpoints returns the owner's endpoints, or every endpoint when
+	// owner is nil.
+	ListEndpoints(ctx context.Context, owner *uuid.UUID) ([]domain.WebhookEndpoint, error)
+	CountEndpoints(ctx context.Context, owner uuid.UUID) (int, error)
+	// ListActiveEndpoints returns the active platform endpoints and those
+	// of the given owners.
+	ListActiveEndpoints(ctx context.Context, owners []uuid.UUID) ([]domain.WebhookEndpoint, error)
+	// CreateDeliveries queues deliveries, skipping any original already
+	// queued for the same endpoint and event. Replays are always queued.
+	CreateDeliveries(ctx context.Context, ds []*domain.WebhookDelivery) error
+	// ClaimDue returns up to limit pending deliveries due at now and
+	// pushes their next attempt back by lease, so that other instances
+	// leave them alone while they are being sent.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, d *domain.WebhookDelivery) error
+	// GetDelivery returns the delivery, or nil.
+	GetDelivery(ctx context.Context, id uuid.UUID) (*domain.WebhookDelivery, error)
+	// ListDeliveries returns an endpoint's deliveries matching filter,
+	// newest first.
+	ListDeliveries(ctx context.Context, endpointID uuid.UUID, filter DeliveryFilter) ([]domain.WebhookDelivery, error)
+	// ListEventDeliveries returns every delivery of an event, oldest first.
+	ListEventDeliveries(ctx context.Context, eventID uuid.UUID) ([]domain.WebhookDelivery, error)
+}
+
+// DeliveryFilter narrows a delivery listing. Zero fields match everything.
+type DeliveryFilter struct {
+	Status    domain.WebhookDeliveryStatus
+	EventType domain.WebhookEventType
+	// From and To bound when the delivery was queued: From inclusive, To
+	// exclusive.
+	From *time.Time
+	To   *time.Time
+	// OriginalsOnly leaves out replays.
+	OriginalsOnly bool
+	Limit         int
+	Offset        int
+}
+
+// TransactionLookup loads the transaction an event is about.
+type TransactionLookup interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error)
+}
+
+// Config tunes delivery.
+type Config struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+	MaxAttempts  int
+	// AllowInsecure accepts plain-HTTP endpoints and delivers to private
+	// addresses. For development only.
+	AllowInsecure bool
+}
+
+// ConfigFromConfig reads the delivery settings from the service config.
+func ConfigFromConfig(cfg config.WebhookConfig) Config {
+	return Config{
+		PollInterval:  cfg.PollInterval,
+		Timeout:       cfg.Timeout,
+		MaxAttempts:   cfg.MaxAttempts,
+		AllowInsecure: cfg.AllowInsecure,
+	}
+}
+
+const (
+	maxEndpointsPerOwner = 10
+	claimBatchSize       = 50
+	maxResponseBody      = 1024
+
+	// DefaultRotationGrace is how long the previous secret keeps signing
+	// deliveries after a rotation unless the caller says otherwise.
+	DefaultRotationGrace = 24 * time.Hour
+	maxRotationGrace     = 7 * 24 * time.Hour
+)
+
+// retrySchedule is the wait before each retry; the last entry repeats.
+var retrySchedule = []time.Duration{
+	time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+}
+
+type Service struct {
+	repo   Repository
+	txs    TransactionLookup
+	cfg    Config
+	client httpDoer
+	logger logger.Logger
+	now    func() time.Time
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+type httpDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+func NewService(repo Repository, txs TransactionLookup, cfg Config, log logger.Logger) *Service {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	return &Service{
+		repo:   repo,
+		txs:    txs,
+		cfg:    cfg,
+		client: newHTTPClient(cfg.Timeout, cfg.AllowInsecure),
+		logger: log,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+// EndpointRequest creates or changes an endpoint. Nil fields are left as
+// they are on update.
+type EndpointRequest struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"`
+	Active      *bool     `json:"active"`
+}
+
+// CreateEndpoint registers an endpoint for owner, or a platform endpoint
+// when owner is nil, and returns it with its signing secret. The secret is
+// not shown again.
+func (s *Service) CreateEndpoint(ctx context.Context, owner *uuid.UUID, createdBy uuid.UUID, req EndpointRequest) (*domain.WebhookEndpoint, string, error) {
+	if req.URL == nil {
+		return nil, "", ErrInvalidEndpoint
+	}
+	if owner != nil {
+		n, err := s.repo.CountEndpoints(ctx, *owner)
+		if err != nil {
+			return nil, "", err
+		}
+		if n >= maxEndpointsPerOwner {
+			return nil, "", ErrTooManyEndpoints
+		}
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	now := s.now().UTC()
+	e := &domain.WebhookEndpoint{
+		ID:        uuid.New(),
+		OwnerID:   owner,
+		Events:    []string{},
+		Secret:    secret,
+		Active:    true,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.apply(e, req); err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.CreateEndpoint(ctx, e); err != nil {
+		return nil, "", err
+	}
+	return e, secret, nil
+}
+
+// UpdateEndpoint changes an endpoint. owner limits the change to that
+// owner's endpoints; nil allows any (admin).
+func (s *Service) UpdateEndpoint(ctx context.Context, owner *uuid.UUID, id uuid.UUID, req EndpointRequest) (*domain.WebhookEndpoint, error) {
+	e, err := s.GetEndpoint(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(e, req); err != nil {
+		return nil, err
+	}
+	e.UpdatedAt = s.now().UTC()
+	if err := s.repo.UpdateEndpoint(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// RotateSecret replaces an endpoint's signing secret and returns the new
+// one. For grace after the rotation deliveries carry a signature with the
+// old secret as well as the new one, so the receiver can deploy the new
+// secret without rejecting anything; a zero grace drops the old secret at
+// once. Deliveries already sent are not re-sent.
+func (s *Service) RotateSecret(ctx context.Context, owner *uuid.UUID, id uuid.UUID, grace time.Duration) (*domain.WebhookEndpoint, string, error) {
+	if grace < 0 || grace > maxRotationGrace {
+		return nil, "", ErrInvalidRotation
+	}
+	e, err := s.GetEndpoint(ctx, owner, id)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	now := s.now().UTC()
+	e.PreviousSecret, e.PreviousSecretExpiresAt = "", nil
+	if grace > 0 {
+		expires := now.Add(grace)
+		e.PreviousSecret, e.PreviousSecretExpiresAt = e.Secret, &expires
+	}
+	e.Secret = secret
+	e.UpdatedAt = now
+	if err := s.repo.UpdateEndpoint(ctx, e); err != nil {
+		return nil, "", err
+	}
+	return e, secret, nil
+}
+
+// DeleteEndpoint removes an endpoint and its delivery log.
+func (s *Service) DeleteEndpoint(ctx context.Context, owner *uuid.UUID, id uuid.UUID) error {
+	if _, err := s.GetEndpoint(ctx, owner, id); err != nil {
+		return err
+	}
+	deleted, err := s.repo.DeleteEndpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
+// GetEndpoint returns an endpoint visible to owner; nil sees all.
+func (s *Service) GetEndpoint(ctx context.Context, owner *uuid.UUID, id uuid.UUID) (*domain.WebhookEndpoint, error) {
+	e, err := s.repo.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || (owner != nil && (e.OwnerID == nil || *e.OwnerID != *owner)) {
+		return nil, ErrEndpointNotFound
+	}
+	return e, nil
+}
+
+// ListEndpoints returns owner's endpoints, or all of them when owner is nil.
+func (s *Service) ListEndpoints(ctx context.Context, owner *uuid.UUID) ([]domain.WebhookEndpoint, error) {
+	return s.repo.ListEndpoints(ctx, owner)
+}
+
+// ListDeliveries returns an endpoint's delivery log, newest first. Each
+// entry carries the payload sent and an excerpt of the response.
+func (s *Service) ListDeliveries(ctx context.Context, owner *uuid.UUID, endpointID uuid.UUID, filter DeliveryFilter) ([]domain.WebhookDelivery, error) {
+	if _, err := s.GetEndpoint(ctx, owner, endpointID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(ctx, endpointID, filter)
+}
+
+func (s *Service) apply(e *domain.WebhookEndpoint, req EndpointRequest) error {
+	if req.URL != nil {
+		u, err := url.Parse(strings.TrimSpace(*req.URL))
+		if err != nil || u.Host == "" || u.User != nil {
+			return ErrInvalidEndpoint
+		}
+		if u.Scheme != "https" && !(s.cfg.AllowInsecure && u.Scheme == "http") {
+			return ErrInvalidEndpoint
+		}
+		e.URL = u.String()
+	}
+	if req.Description != nil {
+		e.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Events != nil {
+		events := []string{}
+		for _, ev := range *req.Events {
+			if !knownEvent(domain.WebhookEventType(ev)) {
+				return ErrInvalidEndpoint
+			}
+			events = append(events, ev)
+		}
+		e.Events = events
+	}
+	if req.Active != nil {
+		e.Active = *req.Active
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,633 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"kyd/internal/domain"
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error {
+	return m.Called(ctx, e).Error(0)
+}
+
+func (m *MockRepository) UpdateEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error {
+	return m.Called(ctx, e).Error(0)
+}
+
+func (m *MockRepository) DeleteEndpoint(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetEndpoint(ctx context.Context, id uuid.UUID) (*domain.WebhookEndpoint, error) {
+	args := m.Called(ctx, id)
+	e, _ := args.Get(0).(*domain.WebhookEndpoint)
+	if e == nil {
+		return nil, args.Error(1)
+	}
+	cp := *e
+	return &cp, args.Error(1)
+}
+
+func (m *MockRepository) ListEndpoints(ctx context.Context, owner *uuid.UUID) ([]domain.WebhookEndpoint, error) {
+	args := m.Called(ctx, owner)
+	endpoints, _ := args.Get(0).([]domain.WebhookEndpoint)
+	return endpoints, args.Error(1)
+}
+
+func (m *MockRepository) CountEndpoints(ctx context.Context, owner uuid.UUID) (int, error) {
+	args := m.Called(ctx, owner)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) ListActiveEndpoints(ctx context.Context, owners []uuid.UUID) ([]domain.WebhookEndpoint, error) {
+	args := m.Called(ctx, owners)
+	endpoints, _ := args.Get(0).([]domain.WebhookEndpoint)
+	return endpoints, args.Error(1)
+}
+
+func (m *MockRepository) CreateDeliveries(ctx context.Context, ds []*domain.WebhookDelivery) error {
+	return m.Called(ctx, ds).Error(0)
+}
+
+func (m *MockRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	args := m.Called(ctx, now, lease, limit)
+	claimed, _ := args.Get(0).([]domain.WebhookDelivery)
+	return claimed, args.Error(1)
+}
+
+func (m *MockRepository) UpdateDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	return m.Called(ctx, d).Error(0)
+}
+
+func (m *MockRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*domain.WebhookDelivery, error) {
+	args := m.Called(ctx, id)
+	d, _ := args.Get(0).(*domain.WebhookDelivery)
+	return d, args.Error(1)
+}
+
+func (m *MockRepository) ListDeliveries(ctx context.Context, endpointID uuid.UUID, filter DeliveryFilter) ([]domain.WebhookDelivery, error) {
+	args := m.Called(ctx, endpointID, filter)
+	deliveries, _ := args.Get(0).([]domain.WebhookDelivery)
+	return deliveries, args.Error(1)
+}
+
+func (m *MockRepository) ListEventDeliveries(ctx context.Context, eventID uuid.UUID) ([]domain.WebhookDelivery, error) {
+	args := m.Called(ctx, eventID)
+	deliveries, _ := args.Get(0).([]domain.WebhookDelivery)
+	return deliveries, args.Error(1)
+}
+
+type MockTransactions struct {
+	mock.Mock
+}
+
+func (m *MockTransactions) FindByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	args := m.Called(ctx, id)
+	tx, _ := args.Get(0).(*domain.Transaction)
+	return tx, args.Error(1)
+}
+
+var (
+	ctx   = context.Background()
+	start = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	// lease is how long a claimed delivery is left alone at the default
+	// timeout.
+	lease = 20 * time.Second
+)
+
+func newService(now *time.Time, maxAttempts int) (*Service, *MockRepository, *MockTransactions) {
+	repo, txs := new(MockRepository), new(MockTransactions)
+	s := NewService(repo, txs, Config{MaxAttempts: maxAttempts, AllowInsecure: true}, logger.NewNop())
+	s.now = func() time.Time { return *now }
+	return s, repo, txs
+}
+
+// endpoint is an active endpoint of owner, or of the platform when owner is
+// nil, subscribed to events or to all of them.
+func endpoint(owner *uuid.UUID, url string, events ...string) *domain.WebhookEndpoint {
+	secret, _ := newSecret()
+	return &domain.WebhookEndpoint{ID: uuid.New(), OwnerID: owner, URL: url, Events: events, Secret: secret, Active: true}
+}
+
+// queued is a delivery of a made-up event to e, due at now.
+func queued(e *domain.WebhookEndpoint, eventType domain.WebhookEventType, now time.Time) domain.WebhookDelivery {
+	eventID := uuid.New()
+	return domain.WebhookDelivery{
+		ID:            uuid.New(),
+		EndpointID:    e.ID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       []byte(`{"id":"` + eventID.String() + `"}`),
+		Status:        domain.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+}
+
+func TestCreateEndpointValidates(t *testing.T) {
+	now := start
+	owner := uuid.New()
+	s, repo, _ := newService(&now, 3)
+	s.cfg.AllowInsecure = false
+	repo.On("CountEndpoints", ctx, owner).Return(0, nil)
+
+	for _, u := range []string{"http://example.com/hook", "ftp://example.com", "https://user:pw@example.com", "not a url"} {
+		u := u
+		_, _, err := s.CreateEndpoint(ctx, &owner, owner, EndpointRequest{URL: &u})
+		assert.ErrorIs(t, err, ErrInvalidEndpoint, u)
+	}
+	u := "https://example.com/hook"
+	unknown := []string{"transaction.exploded"}
+	_, _, err := s.CreateEndpoint(ctx, &owner, owner, EndpointRequest{URL: &u, Events: &unknown})
+	assert.ErrorIs(t, err, ErrInvalidEndpoint)
+	repo.AssertNotCalled(t, "CreateEndpoint", mock.Anything, mock.Anything)
+
+	repo.On("CreateEndpoint", ctx, mock.MatchedBy(func(e *domain.WebhookEndpoint) bool {
+		return *e.OwnerID == owner && e.URL == u && e.Active && e.CreatedAt.Equal(now)
+	})).Return(nil).Once()
+	e, secret, err := s.CreateEndpoint(ctx, &owner, owner, EndpointRequest{URL: &u})
+	require.NoError(t, err)
+	assert.Equal(t, e.Secret, secret)
+	assert.Len(t, secret, len("whsec_")+48)
+	repo.AssertExpectations(t)
+}
+
+func TestCreateEndpointLimit(t *testing.T) {
+	now := start
+	owner := uuid.New()
+	u := "https://example.com/hook"
+	s, repo, _ := newService(&now, 3)
+	repo.On("CountEndpoints", ctx, owner).Return(maxEndpointsPerOwner, nil).Once()
+
+	_, _, err := s.CreateEndpoint(ctx, &owner, owner, EndpointRequest{URL: &u})
+	assert.ErrorIs(t, err, ErrTooManyEndpoints)
+
+	// Platform endpoints are not limited
+	repo.On("CreateEndpoint", ctx, mock.Anything).Return(nil).Once()
+	_, _, err = s.CreateEndpoint(ctx, nil, owner, EndpointRequest{URL: &u})
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestGetEndpoint(t *testing.T) {
+	now := start
+	owner, other := uuid.New(), uuid.New()
+	e := endpoint(&owner, "https://example.com/hook")
+	platform := endpoint(nil, "https://ops.example.com/hook")
+	missing := uuid.New()
+	s, repo, _ := newService(&now, 3)
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+	repo.On("GetEndpoint", ctx, platform.ID).Return(platform, nil)
+	repo.On("GetEndpoint", ctx, missing).Return(nil, nil)
+
+	_, err := s.GetEndpoint(ctx, &owner, e.ID)
+	assert.NoError(t, err)
+	_, err = s.GetEndpoint(ctx, nil, e.ID)
+	assert.NoError(t, err, "admins see every endpoint")
+	_, err = s.GetEndpoint(ctx, &other, e.ID)
+	assert.ErrorIs(t, err, ErrEndpointNotFound)
+	_, err = s.GetEndpoint(ctx, &owner, platform.ID)
+	assert.ErrorIs(t, err, ErrEndpointNotFound)
+	_, err = s.GetEndpoint(ctx, nil, missing)
+	assert.ErrorIs(t, err, ErrEndpointNotFound)
+}
+
+func TestPublishQueuesSubscribedEndpoints(t *testing.T) {
+	now := start
+	tx := &domain.Transaction{
+		ID: uuid.New(), Reference: "KYD-1", Status: domain.TransactionStatusCompleted,
+		SenderID: uuid.New(), ReceiverID: uuid.New(), Amount: decimal.NewFromInt(100), Currency: domain.MWK,
+	}
+	platform := endpoint(nil, "https://ops.example.com/hook")
+	sender := endpoint(&tx.SenderID, "https://merchant.example.com/hook", string(domain.WebhookTransactionCompleted))
+	receiver := endpoint(&tx.ReceiverID, "https://receiver.example.com/hook", string(domain.WebhookTransactionReversed))
+	ev := &domain.TransactionEvent{ID: uuid.New(), TransactionID: tx.ID, Type: domain.TransactionEventDelivered, CreatedAt: now}
+
+	s, repo, txs := newService(&now, 3)
+	txs.On("FindByID", ctx, tx.ID).Return(tx, nil).Once()
+	repo.On("ListActiveEndpoints", ctx, []uuid.UUID{tx.SenderID, tx.ReceiverID}).
+		Return([]domain.WebhookEndpoint{*platform, *sender, *receiver}, nil).Once()
+	repo.On("CreateDeliveries", ctx, mock.MatchedBy(func(ds []*domain.WebhookDelivery) bool {
+		if len(ds) != 2 || ds[0].EndpointID != platform.ID || ds[1].EndpointID != sender.ID {
+			return false
+		}
+		for _, d := range ds {
+			var p Payload
+			if json.Unmarshal(d.Payload, &p) != nil || p.ID != ev.ID || p.Data.Transaction.ID != tx.ID ||
+				d.EventID != ev.ID || d.EventType != domain.WebhookTransactionCompleted ||
+				d.Status != domain.WebhookDeliveryPending || !d.NextAttemptAt.Equal(now) {
+				return false
+			}
+		}
+		return true
+	})).Return(nil).Once()
+
+	require.NoError(t, s.Publish(ctx, ev))
+	repo.AssertExpectations(t)
+	txs.AssertExpectations(t)
+
+	t.Run("not a milestone", func(t *testing.T) {
+		s, repo, txs := newService(&now, 3)
+		require.NoError(t, s.Publish(ctx, &domain.TransactionEvent{ID: uuid.New(), TransactionID: tx.ID, Type: "note_added"}))
+		txs.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "CreateDeliveries", mock.Anything, mock.Anything)
+	})
+
+	t.Run("nobody subscribed", func(t *testing.T) {
+		s, repo, txs := newService(&now, 3)
+		txs.On("FindByID", ctx, tx.ID).Return(tx, nil).Once()
+		repo.On("ListActiveEndpoints", ctx, mock.Anything).Return([]domain.WebhookEndpoint{*receiver}, nil).Once()
+		require.NoError(t, s.Publish(ctx, ev))
+		repo.AssertNotCalled(t, "CreateDeliveries", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPublishStatementQueuesOwnerEndpoints(t *testing.T) {
+	now := start
+	owner := uuid.New()
+	erp := endpoint(&owner, "https://erp.example.com/hook", string(domain.WebhookStatementReady))
+	merchant := endpoint(&owner, "https://merchant.example.com/hook", string(domain.WebhookTransactionCompleted))
+	disabled := endpoint(&owner, "https://old.example.com/hook")
+	disabled.Active = false
+	d := &domain.StatementDelivery{
+		ID: uuid.New(), OwnerID: owner, WalletID: uuid.New(), Period: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Format: domain.StatementFormatCSV, FileName: "statement.csv", Content: []byte("type,booked_at\n"),
+	}
+
+	s, repo, _ := newService(&now, 3)
+	repo.On("ListEndpoints", ctx, &owner).Return([]domain.WebhookEndpoint{*erp, *merchant, *disabled}, nil).Once()
+	repo.On("CreateDeliveries", ctx, mock.MatchedBy(func(ds []*domain.WebhookDelivery) bool {
+		var p StatementPayload
+		return len(ds) == 1 && ds[0].EndpointID == erp.ID && ds[0].EventID == d.ID &&
+			json.Unmarshal(ds[0].Payload, &p) == nil &&
+			p.Data.Statement.Period == "2026-03-01" && string(p.Data.Statement.Content) == string(d.Content)
+	})).Return(nil).Once()
+
+	n, err := s.PublishStatement(ctx, d)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	repo.AssertExpectations(t)
+
+	// Nobody else's endpoints get it
+	s, repo, _ = newService(&now, 3)
+	stranger := uuid.New()
+	repo.On("ListEndpoints", ctx, &stranger).Return([]domain.WebhookEndpoint{}, nil).Once()
+	n, err = s.PublishStatement(ctx, &domain.StatementDelivery{ID: uuid.New(), OwnerID: stranger})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	repo.AssertNotCalled(t, "CreateDeliveries", mock.Anything, mock.Anything)
+}
+
+// recorder is an endpoint that answers with status and keeps what it got.
+type recorder struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+	status := rec.status
+	rec.mu.Unlock()
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+	w.Write([]byte("ok"))
+}
+
+func TestDeliverSignsAndRecordsSuccess(t *testing.T) {
+	now := start
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	e := endpoint(nil, srv.URL)
+	d := queued(e, domain.WebhookTransactionCreated, now)
+
+	s, repo, _ := newService(&now, 3)
+	repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return([]domain.WebhookDelivery{d}, nil).Once()
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil).Once()
+	repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(got *domain.WebhookDelivery) bool {
+		return got.ID == d.ID && got.Status == domain.WebhookDeliverySucceeded && got.Attempts == 1 &&
+			got.ResponseStatus == http.StatusOK && got.ResponseBody == "ok" &&
+			got.DeliveredAt.Equal(now) && got.NextAttemptAt == nil
+	})).Return(nil).Once()
+
+	assert.Equal(t, 1, s.DeliverDue(ctx))
+	repo.AssertExpectations(t)
+
+	require.Len(t, rec.requests, 1)
+	r := rec.requests[0]
+	assert.Equal(t, string(domain.WebhookTransactionCreated), r.Header.Get(HeaderEvent))
+	assert.Equal(t, d.EventID.String(), r.Header.Get(HeaderEventID))
+	assert.Equal(t, d.ID.String(), r.Header.Get(HeaderDelivery))
+	assert.Empty(t, r.Header.Get(HeaderReplayOf))
+	assert.Equal(t, []byte(d.Payload), rec.bodies[0])
+	assert.NoError(t, Verify(e.Secret, r.Header.Get(HeaderSignature), rec.bodies[0], now, DefaultTolerance))
+
+	t.Run("replays say what they replay", func(t *testing.T) {
+		replay := queued(e, domain.WebhookTransactionCreated, now)
+		replay.ReplayOf = &d.ID
+		s, repo, _ := newService(&now, 3)
+		repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return([]domain.WebhookDelivery{replay}, nil).Once()
+		repo.On("GetEndpoint", ctx, e.ID).Return(e, nil).Once()
+		repo.On("UpdateDelivery", ctx, mock.Anything).Return(nil).Once()
+
+		s.DeliverDue(ctx)
+		require.Len(t, rec.requests, 2)
+		assert.Equal(t, d.ID.String(), rec.requests[1].Header.Get(HeaderReplayOf))
+	})
+}
+
+func TestDeliverRetriesThenFails(t *testing.T) {
+	rec := &recorder{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	e := endpoint(nil, srv.URL)
+
+	tests := []struct {
+		name     string
+		attempts int
+		status   domain.WebhookDeliveryStatus
+		retry    time.Duration
+	}{
+		{"first failure", 0, domain.WebhookDeliveryPending, retryDelay(1)},
+		{"second failure", 1, domain.WebhookDeliveryPending, retryDelay(2)},
+		{"gives up", 2, domain.WebhookDeliveryFailed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			d := queued(e, domain.WebhookTransactionFailed, now)
+			d.Attempts = tt.attempts
+			s, repo, _ := newService(&now, 3)
+			repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return([]domain.WebhookDelivery{d}, nil).Once()
+			repo.On("GetEndpoint", ctx, e.ID).Return(e, nil).Once()
+			repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(got *domain.WebhookDelivery) bool {
+				retries := got.NextAttemptAt != nil && got.NextAttemptAt.Equal(now.Add(tt.retry))
+				return got.Status == tt.status && got.Attempts == tt.attempts+1 &&
+					got.ResponseStatus == http.StatusInternalServerError && got.LastError == "endpoint returned 500" &&
+					got.DeliveredAt == nil && retries == (tt.retry > 0) && (retries || got.NextAttemptAt == nil)
+			})).Return(nil).Once()
+
+			assert.Equal(t, 1, s.DeliverDue(ctx))
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeliverToDisabledEndpointFails(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	disabled := endpoint(nil, srv.URL)
+	disabled.Active = false
+
+	for name, e := range map[string]*domain.WebhookEndpoint{"disabled": disabled, "deleted": nil} {
+		t.Run(name, func(t *testing.T) {
+			now := start
+			d := queued(disabled, domain.WebhookTransactionCompleted, now)
+			s, repo, _ := newService(&now, 3)
+			repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return([]domain.WebhookDelivery{d}, nil).Once()
+			repo.On("GetEndpoint", ctx, disabled.ID).Return(e, nil).Once()
+			repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(got *domain.WebhookDelivery) bool {
+				return got.Status == domain.WebhookDeliveryFailed && got.Attempts == 0 && got.NextAttemptAt == nil && got.LastError == "endpoint disabled"
+			})).Return(nil).Once()
+
+			s.DeliverDue(ctx)
+			repo.AssertExpectations(t)
+		})
+	}
+	assert.Empty(t, rec.requests)
+}
+
+func TestDeliverDueClaimsInBatches(t *testing.T) {
+	now := start
+	e := endpoint(nil, "https://ops.example.com/hook")
+	e.Active = false
+	full := make([]domain.WebhookDelivery, claimBatchSize)
+	for i := range full {
+		full[i] = queued(e, domain.WebhookTransactionCompleted, now)
+	}
+
+	s, repo, _ := newService(&now, 3)
+	repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return(full, nil).Once()
+	repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return(full[:1], nil).Once()
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil).Twice()
+	repo.On("UpdateDelivery", ctx, mock.Anything).Return(nil).Times(claimBatchSize + 1)
+
+	assert.Equal(t, claimBatchSize+1, s.DeliverDue(ctx))
+	repo.AssertExpectations(t)
+
+	s, repo, _ = newService(&now, 3)
+	repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return(nil, errors.New("connection refused")).Once()
+	assert.Zero(t, s.DeliverDue(ctx))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, retryDelay(0))
+	assert.Equal(t, time.Minute, retryDelay(1))
+	assert.Equal(t, 30*time.Minute, retryDelay(3))
+	assert.Equal(t, 12*time.Hour, retryDelay(20))
+}
+
+func TestPrivateAddressesBlocked(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := newHTTPClient(time.Second, false)
+	_, err := client.Get(srv.URL)
+	assert.ErrorIs(t, err, errBlockedAddress)
+}
+
+func TestRotateSecret(t *testing.T) {
+	now := start
+	e := endpoint(nil, "https://ops.example.com/hook")
+	s, repo, _ := newService(&now, 3)
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+
+	_, _, err := s.RotateSecret(ctx, nil, e.ID, maxRotationGrace+time.Hour)
+	assert.ErrorIs(t, err, ErrInvalidRotation)
+
+	repo.On("UpdateEndpoint", ctx, mock.MatchedBy(func(got *domain.WebhookEndpoint) bool {
+		return got.PreviousSecret == e.Secret && got.PreviousSecretExpiresAt.Equal(now.Add(time.Hour))
+	})).Return(nil).Once()
+	rotated, secret, err := s.RotateSecret(ctx, nil, e.ID, time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, e.Secret, secret)
+	assert.Equal(t, secret, rotated.Secret)
+
+	// A rotation without grace drops the old secret at once.
+	repo.On("UpdateEndpoint", ctx, mock.MatchedBy(func(got *domain.WebhookEndpoint) bool {
+		return got.PreviousSecret == "" && got.PreviousSecretExpiresAt == nil
+	})).Return(nil).Once()
+	rotated, _, err = s.RotateSecret(ctx, nil, e.ID, 0)
+	require.NoError(t, err)
+	assert.Len(t, rotated.SigningSecrets(now), 1)
+	repo.AssertExpectations(t)
+}
+
+func TestRotatedSecretSignsDuringGrace(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	e := endpoint(nil, srv.URL)
+	oldSecret := e.Secret
+	e.Secret, _ = newSecret()
+	expires := start.Add(time.Hour)
+	e.PreviousSecret, e.PreviousSecretExpiresAt = oldSecret, &expires
+
+	for i, now := range []time.Time{start, expires} {
+		s, repo, _ := newService(&now, 3)
+		repo.On("ClaimDue", ctx, now, lease, claimBatchSize).Return([]domain.WebhookDelivery{queued(e, domain.WebhookTransactionCreated, now)}, nil).Once()
+		repo.On("GetEndpoint", ctx, e.ID).Return(e, nil).Once()
+		repo.On("UpdateDelivery", ctx, mock.Anything).Return(nil).Once()
+		s.DeliverDue(ctx)
+		require.Len(t, rec.requests, i+1)
+		sig := rec.requests[i].Header.Get(HeaderSignature)
+		assert.NoError(t, Verify(e.Secret, sig, rec.bodies[i], now, DefaultTolerance))
+		if i == 0 {
+			assert.NoError(t, Verify(oldSecret, sig, rec.bodies[i], now, DefaultTolerance), "both sign during the grace period")
+		} else {
+			assert.ErrorIs(t, Verify(oldSecret, sig, rec.bodies[i], now, DefaultTolerance), ErrInvalidSignature, "only the new one after it")
+		}
+	}
+}
+
+func TestReplayDelivery(t *testing.T) {
+	now := start
+	owner := uuid.New()
+	e := endpoint(&owner, "https://merchant.example.com/hook")
+	original := queued(e, domain.WebhookTransactionCreated, now)
+	original.Status = domain.WebhookDeliverySucceeded
+	replayed := queued(e, domain.WebhookTransactionCreated, now)
+	replayed.Status, replayed.EventID, replayed.ReplayOf = domain.WebhookDeliveryFailed, original.EventID, &original.ID
+	pending := queued(e, domain.WebhookTransactionCreated, now)
+	elsewhere := queued(endpoint(&owner, "https://erp.example.com/hook"), domain.WebhookTransactionCreated, now)
+	elsewhere.Status = domain.WebhookDeliverySucceeded
+	missing := uuid.New()
+
+	s, repo, _ := newService(&now, 3)
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+	for _, d := range []domain.WebhookDelivery{original, replayed, pending, elsewhere} {
+		d := d
+		repo.On("GetDelivery", ctx, d.ID).Return(&d, nil)
+	}
+	repo.On("GetDelivery", ctx, missing).Return(nil, nil)
+
+	_, err := s.Replay(ctx, &owner, e.ID, pending.ID)
+	assert.ErrorIs(t, err, ErrInvalidReplay, "a pending delivery cannot be replayed")
+	stranger := uuid.New()
+	_, err = s.Replay(ctx, &stranger, e.ID, original.ID)
+	assert.ErrorIs(t, err, ErrEndpointNotFound)
+	_, err = s.Replay(ctx, &owner, e.ID, missing)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+	_, err = s.Replay(ctx, &owner, e.ID, elsewhere.ID)
+	assert.ErrorIs(t, err, ErrDeliveryNotFound, "another endpoint's delivery")
+	repo.AssertNotCalled(t, "CreateDeliveries", mock.Anything, mock.Anything)
+
+	// Replays, of the original or of a replay, point at the original.
+	for _, d := range []domain.WebhookDelivery{original, replayed} {
+		repo.On("CreateDeliveries", ctx, mock.MatchedBy(func(ds []*domain.WebhookDelivery) bool {
+			return len(ds) == 1 && ds[0].ID != d.ID && *ds[0].ReplayOf == original.ID && ds[0].EventID == original.EventID &&
+				string(ds[0].Payload) == string(d.Payload) && ds[0].Status == domain.WebhookDeliveryPending
+		})).Return(nil).Once()
+		replay, err := s.Replay(ctx, &owner, e.ID, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, original.ID, *replay.ReplayOf)
+	}
+	repo.AssertExpectations(t)
+
+	t.Run("disabled endpoint", func(t *testing.T) {
+		disabled := *e
+		disabled.Active = false
+		s, repo, _ := newService(&now, 3)
+		repo.On("GetEndpoint", ctx, e.ID).Return(&disabled, nil).Once()
+		_, err := s.Replay(ctx, &owner, e.ID, original.ID)
+		assert.ErrorIs(t, err, ErrInvalidReplay)
+	})
+}
+
+func TestReplayRange(t *testing.T) {
+	now := start
+	from, to := now.Add(-time.Hour), now
+	e := endpoint(nil, "https://ops.example.com/hook")
+	s, repo, _ := newService(&now, 3)
+
+	for name, req := range map[string]ReplayRequest{
+		"backwards":     {From: to, To: from},
+		"no end":        {From: from},
+		"pending":       {From: from, To: to, Status: domain.WebhookDeliveryPending},
+		"unknown event": {From: from, To: to, EventType: "transaction.exploded"},
+	} {
+		_, err := s.ReplayRange(ctx, nil, e.ID, req)
+		assert.ErrorIs(t, err, ErrInvalidReplay, name)
+	}
+	repo.AssertNotCalled(t, "ListDeliveries", mock.Anything, mock.Anything, mock.Anything)
+
+	failed := make([]domain.WebhookDelivery, 3)
+	for i := range failed {
+		failed[i] = queued(e, domain.WebhookTransactionCompleted, from)
+		failed[i].Status = domain.WebhookDeliveryFailed
+	}
+	filter := DeliveryFilter{Status: domain.WebhookDeliveryFailed, From: &from, To: &to, OriginalsOnly: true, Limit: maxReplayBatch + 1}
+	repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+	repo.On("ListDeliveries", ctx, e.ID, filter).Return(failed, nil).Once()
+	repo.On("CreateDeliveries", ctx, mock.MatchedBy(func(ds []*domain.WebhookDelivery) bool {
+		if len(ds) != 3 {
+			return false
+		}
+		for i, d := range ds {
+			if *d.ReplayOf != failed[i].ID {
+				return false
+			}
+		}
+		return true
+	})).Return(nil).Once()
+
+	replays, err := s.ReplayRange(ctx, nil, e.ID, ReplayRequest{From: from, To: to, Status: domain.WebhookDeliveryFailed})
+	require.NoError(t, err)
+	assert.Len(t, replays, 3)
+	repo.AssertExpectations(t)
+
+	t.Run("still pending are left out", func(t *testing.T) {
+		s, repo, _ := newService(&now, 3)
+		repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+		repo.On("ListDeliveries", ctx, e.ID, mock.Anything).Return([]domain.WebhookDelivery{queued(e, domain.WebhookTransactionCompleted, from)}, nil).Once()
+		replays, err := s.ReplayRange(ctx, nil, e.ID, ReplayRequest{From: from, To: to})
+		require.NoError(t, err)
+		assert.Empty(t, replays)
+		repo.AssertNotCalled(t, "CreateDeliveries", mock.Anything, mock.Anything)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		s, repo, _ := newService(&now, 3)
+		repo.On("GetEndpoint", ctx, e.ID).Return(e, nil)
+		repo.On("ListDeliveries", ctx, e.ID, mock.Anything).Return(make([]domain.WebhookDelivery, maxReplayBatch+1), nil).Once()
+		_, err := s.ReplayRange(ctx, nil, e.ID, ReplayRequest{From: from, To: to})
+		assert.ErrorIs(t, err, ErrReplayTooLarge)
+		repo.AssertNotCalled(t, "CreateDeliveries", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,29 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt"}`)
+	header := Sign("whsec_test", now, body)
+
+	assert.NoError(t, Verify("whsec_test", header, body, now.Add(time.Minute), DefaultTolerance))
+	assert.ErrorIs(t, Verify("whsec_other", header, body, now, DefaultTolerance), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, []byte(`{"id":"evil"}`), now, DefaultTolerance), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, body, now.Add(10*time.Minute), DefaultTolerance), ErrSignatureExpired)
+	assert.ErrorIs(t, Verify("whsec_test", "garbage", body, now, DefaultTolerance), ErrInvalidSignature)
+}
+
+func TestVerifyAcceptsAnyListedSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{}`)
+	header := Sign("whsec_new", now, body) + ",v1=" + computeMAC("whsec_old", "1700000000", body)
+
+	assert.NoError(t, Verify("whsec_old", header, body, now, DefaultTolerance))
+	assert.NoError(t, Verify("whsec_new", header, body, now, DefaultTolerance))
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"kyd/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// StatementPayload is the body of a statement.ready delivery.
+type StatementPayload struct {
+	ID        uuid.UUID               `json:"id"`
+	Type      domain.WebhookEventType `json:"type"`
+	CreatedAt time.Time               `json:"created_at"`
+	Data      StatementData           `json:"data"`
+}
+
+type StatementData struct {
+	Statement StatementFile `json:"statement"`
+}
+
+// StatementFile is a wallet statement with the file itself, base64 encoded,
+// so that the ERP receiving it needs no further call.
+type StatementFile struct {
+	ID              uuid.UUID              `json:"id"`
+	WalletID        uuid.UUID              `json:"wallet_id"`
+	Period          string                 `json:"period"`
+	Format          domain.StatementFormat `json:"format"`
+	RegeneratedFrom *uuid.UUID             `json:"regenerated_from,omitempty"`
+	FileName        string                 `json:"file_name"`
+	FileSize        int64                  `json:"file_size"`
+	SHA256          string                 `json:"sha256"`
+	EntryCount      int                    `json:"entry_count"`
+	Content         []byte                 `json:"content"`
+}
+
+// PublishStatement queues a statement.ready delivery of d to its owner's
+// active endpoints subscribed to it, and returns how many it queued. The
+// event ID is the statement delivery's, so each is sent at most once.
+func (s *Service) PublishStatement(ctx context.Context, d *domain.StatementDelivery) (int, error) {
+	endpoints, err := s.repo.ListEndpoints(ctx, &d.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	subscribed := endpoints[:0]
+	for _, e := range endpoints {
+		if e.Active && e.Subscribes(domain.WebhookStatementReady) {
+			subscribed = append(subscribed, e)
+		}
+	}
+	if len(subscribed) == 0 {
+		return 0, nil
+	}
+	body, err := json.Marshal(StatementPayload{
+		ID:        d.ID,
+		Type:      domain.WebhookStatementReady,
+		CreatedAt: s.now().UTC(),
+		Data: StatementData{Statement: StatementFile{
+			ID:              d.ID,
+			WalletID:        d.WalletID,
+			Period:          d.Period.Format("2006-01-02"),
+			Format:          d.Format,
+			RegeneratedFrom: d.RegeneratedFrom,
+			FileName:        d.FileName,
+			FileSize:        d.FileSize,
+			SHA256:          d.SHA256,
+			EntryCount:      d.EntryCount,
+			Content:         d.Content,
+		}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := s.queue(ctx, subscribed, d.ID, domain.WebhookStatementReady, body); err != nil {
+		return 0, err
+	}
+	return len(subscribed), nil
+}
+
+// EventDeliveries returns every delivery of an event, replays included.
+func (s *Service) EventDeliveries(ctx context.Context, eventID uuid.UUID) ([]domain.WebhookDelivery, error) {
+	return s.repo.ListEventDeliveries(ctx, eventID)
+}
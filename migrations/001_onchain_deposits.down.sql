@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_schema.chain_scan_cursors;
+DROP TABLE IF EXISTS customer_schema.onchain_deposits;
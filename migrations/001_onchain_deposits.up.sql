@@ -0,0 +1,33 @@
+-- Inbound on-chain deposits detected on our receiving addresses.
+
+CREATE TABLE IF NOT EXISTS customer_schema.onchain_deposits (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    network VARCHAR(20) NOT NULL,
+    tx_hash VARCHAR(255) NOT NULL,
+    receiving_address VARCHAR(255) NOT NULL,
+    sender_address VARCHAR(255) NOT NULL DEFAULT '',
+    memo VARCHAR(255) NOT NULL DEFAULT '',
+    amount DECIMAL(20, 8) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    block_height BIGINT NOT NULL,
+    block_hash VARCHAR(255) NOT NULL,
+    confirmations BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'detected' CHECK (status IN ('detected', 'confirmed', 'credited', 'unmatched', 'orphaned', 'failed')),
+    wallet_id UUID REFERENCES customer_schema.wallets(id),
+    credit_reference VARCHAR(100),
+    failure_reason TEXT,
+    confirmed_at TIMESTAMPTZ,
+    credited_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (network, tx_hash, receiving_address)
+);
+
+CREATE INDEX IF NOT EXISTS idx_onchain_deposits_network_status ON customer_schema.onchain_deposits(network, status, block_height);
+CREATE INDEX IF NOT EXISTS idx_onchain_deposits_wallet_id ON customer_schema.onchain_deposits(wallet_id);
+
+CREATE TABLE IF NOT EXISTS customer_schema.chain_scan_cursors (
+    network VARCHAR(20) PRIMARY KEY,
+    last_height BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS customer_schema.idx_settlements_network_created_at;
+
+ALTER TABLE customer_schema.settlements
+  DROP COLUMN IF EXISTS network_fee;
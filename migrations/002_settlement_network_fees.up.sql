@@ -0,0 +1,6 @@
+-- Record the on-chain fee actually paid for each settlement submission.
+
+ALTER TABLE customer_schema.settlements
+  ADD COLUMN IF NOT EXISTS network_fee DECIMAL(20,8) NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_settlements_network_created_at ON customer_schema.settlements(network, created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS admin_schema.idx_settlement_routes_corridor;
+
+DROP TABLE IF EXISTS admin_schema.settlement_routes;
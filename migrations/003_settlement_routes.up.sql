@@ -0,0 +1,20 @@
+-- Admin-maintained mapping of corridor and amount band to settlement rail.
+-- A route with a future effective_from is a scheduled switchover.
+
+CREATE TABLE IF NOT EXISTS admin_schema.settlement_routes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    corridor VARCHAR(7) NOT NULL,
+    min_amount DECIMAL(20, 2) NOT NULL DEFAULT 0 CHECK (min_amount >= 0),
+    max_amount DECIMAL(20, 2),
+    network VARCHAR(20) NOT NULL CHECK (network IN ('stellar', 'ripple')),
+    effective_from TIMESTAMPTZ NOT NULL,
+    effective_to TIMESTAMPTZ,
+    notes TEXT,
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (max_amount IS NULL OR max_amount > min_amount),
+    CHECK (effective_to IS NULL OR effective_to > effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_routes_corridor ON admin_schema.settlement_routes(corridor, effective_from);
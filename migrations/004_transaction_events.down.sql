@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS customer_schema.idx_transaction_events_transaction_id;
+
+DROP TABLE IF EXISTS customer_schema.transaction_events;
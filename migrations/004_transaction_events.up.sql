@@ -0,0 +1,12 @@
+-- Append-only stream of lifecycle milestones per transaction, used to build
+-- the customer-facing payment timeline.
+
+CREATE TABLE IF NOT EXISTS customer_schema.transaction_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES customer_schema.transactions(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_events_transaction_id ON customer_schema.transaction_events(transaction_id, created_at);
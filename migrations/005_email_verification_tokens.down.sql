@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS customer_schema.idx_email_verification_tokens_user_id;
+
+DROP TABLE IF EXISTS customer_schema.email_verification_tokens;
@@ -0,0 +1,11 @@
+-- Verification emails sent per user, for resend throttling and single-use links.
+
+CREATE TABLE IF NOT EXISTS customer_schema.email_verification_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON customer_schema.email_verification_tokens(user_id, created_at DESC);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS customer_schema.idx_phone_verification_codes_user_id;
+
+DROP TABLE IF EXISTS customer_schema.phone_verification_codes;
+
+ALTER TABLE customer_schema.users DROP COLUMN IF EXISTS phone_verified_at;
+ALTER TABLE customer_schema.users DROP COLUMN IF EXISTS phone_verified;
@@ -0,0 +1,16 @@
+-- Phone verification state on users and the SMS one-time codes sent to them.
+
+ALTER TABLE customer_schema.users ADD COLUMN IF NOT EXISTS phone_verified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE customer_schema.users ADD COLUMN IF NOT EXISTS phone_verified_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS customer_schema.phone_verification_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone_verification_codes_user_id ON customer_schema.phone_verification_codes(user_id, created_at DESC);
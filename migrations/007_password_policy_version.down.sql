@@ -0,0 +1 @@
+ALTER TABLE customer_schema.users DROP COLUMN IF EXISTS password_policy_version;
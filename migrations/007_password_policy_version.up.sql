@@ -0,0 +1,4 @@
+-- Records which password policy each stored password was last checked
+-- against, so that raising the policy can force an upgrade on next login.
+
+ALTER TABLE customer_schema.users ADD COLUMN IF NOT EXISTS password_policy_version INTEGER NOT NULL DEFAULT 1;
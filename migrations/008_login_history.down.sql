@@ -0,0 +1,10 @@
+ALTER TABLE customer_schema.users DROP COLUMN IF EXISTS login_alerts_disabled;
+
+DROP INDEX IF EXISTS admin_schema.idx_security_events_user_created;
+
+DELETE FROM admin_schema.security_events WHERE event_type = 'login_success';
+ALTER TABLE admin_schema.security_events DROP CONSTRAINT IF EXISTS security_events_event_type_check;
+ALTER TABLE admin_schema.security_events ADD CONSTRAINT security_events_event_type_check CHECK (event_type IN (
+    'brute_force_attempt', 'suspicious_ip', 'velocity_limit_exceeded',
+    'admin_login_failed', 'multiple_failed_kyc', 'blacklisted_device_detected'
+));
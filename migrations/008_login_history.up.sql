@@ -0,0 +1,13 @@
+-- Successful logins are recorded as security events so users can review their
+-- login history, and users can opt out of new-device login alerts.
+
+ALTER TABLE admin_schema.security_events DROP CONSTRAINT IF EXISTS security_events_event_type_check;
+ALTER TABLE admin_schema.security_events ADD CONSTRAINT security_events_event_type_check CHECK (event_type IN (
+    'brute_force_attempt', 'suspicious_ip', 'velocity_limit_exceeded',
+    'admin_login_failed', 'multiple_failed_kyc', 'blacklisted_device_detected',
+    'login_success'
+));
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_created ON admin_schema.security_events(user_id, created_at DESC);
+
+ALTER TABLE customer_schema.users ADD COLUMN IF NOT EXISTS login_alerts_disabled BOOLEAN NOT NULL DEFAULT FALSE;
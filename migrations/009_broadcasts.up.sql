@@ -0,0 +1,30 @@
+-- Operator broadcasts to user segments and their per-recipient delivery log.
+-- Read rates come from the notification each delivery created.
+
+CREATE TABLE IF NOT EXISTS admin_schema.broadcasts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    segment JSONB NOT NULL DEFAULT '{}',
+    channels TEXT[] NOT NULL DEFAULT '{email}',
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'sending', 'sent', 'cancelled')),
+    scheduled_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcasts_due ON admin_schema.broadcasts(scheduled_at) WHERE status IN ('scheduled', 'sending');
+
+CREATE TABLE IF NOT EXISTS admin_schema.broadcast_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    broadcast_id UUID NOT NULL REFERENCES admin_schema.broadcasts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    notification_id UUID,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('delivered', 'failed')),
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (broadcast_id, user_id)
+);
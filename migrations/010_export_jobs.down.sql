@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS customer_schema.idx_transactions_created_id;
+DROP INDEX IF EXISTS admin_schema.idx_audit_logs_created_id;
+DROP TABLE IF EXISTS admin_schema.export_jobs;
@@ -0,0 +1,27 @@
+-- Asynchronous admin exports. Files live on the exporting service's disk
+-- (EXPORT_DIR) and are deleted once expires_at passes.
+
+CREATE TABLE IF NOT EXISTS admin_schema.export_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(50) NOT NULL CHECK (kind IN ('audit_logs', 'transactions')),
+    filters JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed', 'expired')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    file_path TEXT NOT NULL DEFAULT '',
+    file_size BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    requested_by UUID NOT NULL,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_pending ON admin_schema.export_jobs(created_at) WHERE status IN ('queued', 'running');
+CREATE INDEX IF NOT EXISTS idx_export_jobs_expiry ON admin_schema.export_jobs(expires_at) WHERE status = 'completed';
+
+-- Keyset pagination for exports walks these in (created_at, id) order.
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_id ON admin_schema.audit_logs(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_id ON customer_schema.transactions(created_at, id);
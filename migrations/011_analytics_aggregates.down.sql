@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS customer_schema.idx_users_created;
+DROP INDEX IF EXISTS customer_schema.idx_tx_sender_completed_created;
+DROP TABLE IF EXISTS admin_schema.analytics_sender_retention;
+DROP TABLE IF EXISTS admin_schema.analytics_funnel_daily;
@@ -0,0 +1,28 @@
+-- Daily precomputed aggregates behind the admin funnel and retention
+-- reports. Rows are upserted by the analytics refresh job.
+
+CREATE TABLE IF NOT EXISTS admin_schema.analytics_funnel_daily (
+    day DATE NOT NULL,
+    corridor VARCHAR(2) NOT NULL,
+    registered INTEGER NOT NULL DEFAULT 0,
+    kyc_verified INTEGER NOT NULL DEFAULT 0,
+    first_payment INTEGER NOT NULL DEFAULT 0,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (day, corridor)
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.analytics_sender_retention (
+    cohort_month DATE NOT NULL,
+    activity_month DATE NOT NULL,
+    corridor VARCHAR(2) NOT NULL,
+    senders INTEGER NOT NULL DEFAULT 0,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (cohort_month, activity_month, corridor)
+);
+
+-- Supports the per-sender lookups made by the refresh queries.
+CREATE INDEX IF NOT EXISTS idx_tx_sender_completed_created
+    ON customer_schema.transactions(sender_id, created_at)
+    WHERE status = 'completed';
+
+CREATE INDEX IF NOT EXISTS idx_users_created ON customer_schema.users(created_at);
@@ -0,0 +1,17 @@
+-- Idempotency keys for ledger postings. A key is claimed in the same
+-- transaction that writes the entries, so it is only consumed by a posting
+-- that committed, and a retry replays the recorded entries instead of
+-- posting again.
+
+CREATE TABLE IF NOT EXISTS customer_schema.ledger_postings (
+    idempotency_key VARCHAR(255) PRIMARY KEY,
+    transaction_id UUID NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    debit_entry_id UUID REFERENCES customer_schema.ledger_entries(id),
+    credit_entry_id UUID REFERENCES customer_schema.ledger_entries(id),
+    fee_entry_id UUID REFERENCES customer_schema.ledger_entries(id),
+    posted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_transaction ON customer_schema.ledger_postings(transaction_id);
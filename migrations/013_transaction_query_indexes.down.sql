@@ -0,0 +1,8 @@
+CREATE INDEX IF NOT EXISTS idx_tx_sender ON customer_schema.transactions(sender_id);
+CREATE INDEX IF NOT EXISTS idx_tx_receiver ON customer_schema.transactions(receiver_id);
+CREATE INDEX IF NOT EXISTS idx_tx_status ON customer_schema.transactions(status);
+
+DROP INDEX IF EXISTS customer_schema.idx_tx_pending_settlement;
+DROP INDEX IF EXISTS customer_schema.idx_tx_status_created_id;
+DROP INDEX IF EXISTS customer_schema.idx_tx_receiver_created_id;
+DROP INDEX IF EXISTS customer_schema.idx_tx_sender_created_id;
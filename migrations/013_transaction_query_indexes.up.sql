@@ -0,0 +1,25 @@
+-- Composite indexes backing the keyset-paginated transaction listings and
+-- the settlement batch pick-up. Each index matches the WHERE and ORDER BY of
+-- one repository query so rows come back in index order without a sort.
+-- Newest-first listings scan the ascending indexes backwards, which keeps
+-- the (created_at, id) row comparison of the keyset predicate indexable.
+
+-- FindByUserID / FindByUserIDAfter: one branch per side of the payment.
+CREATE INDEX IF NOT EXISTS idx_tx_sender_created_id
+    ON customer_schema.transactions(sender_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_tx_receiver_created_id
+    ON customer_schema.transactions(receiver_id, created_at, id);
+
+-- FindByStatus / FindByStatusAfter.
+CREATE INDEX IF NOT EXISTS idx_tx_status_created_id
+    ON customer_schema.transactions(status, created_at, id);
+
+-- FindPendingSettlement only ever reads unbatched pending rows.
+CREATE INDEX IF NOT EXISTS idx_tx_pending_settlement
+    ON customer_schema.transactions(completed_at, id)
+    WHERE status = 'pending_settlement' AND settlement_id IS NULL;
+
+-- Superseded by the composite indexes above.
+DROP INDEX IF EXISTS customer_schema.idx_tx_sender;
+DROP INDEX IF EXISTS customer_schema.idx_tx_receiver;
+DROP INDEX IF EXISTS customer_schema.idx_tx_status;
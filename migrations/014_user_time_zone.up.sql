@@ -0,0 +1,4 @@
+-- Users choose the IANA zone their receipts and statements are presented in.
+-- Timestamps stay stored in UTC; this is presentation only.
+
+ALTER TABLE customer_schema.users ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS customer_schema.uq_tx_reference_number;
+ALTER TABLE customer_schema.transactions DROP COLUMN IF EXISTS reference_number;
@@ -0,0 +1,6 @@
+-- Human-readable, check-digit reference numbers (pkg/reference) for receipts
+-- and support lookups, separate from the client-supplied idempotency
+-- reference. Transactions created before this migration have none.
+
+ALTER TABLE customer_schema.transactions ADD COLUMN IF NOT EXISTS reference_number VARCHAR(20);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_tx_reference_number ON customer_schema.transactions(reference_number);
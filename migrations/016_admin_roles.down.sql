@@ -0,0 +1 @@
+ALTER TABLE customer_schema.users DROP COLUMN IF EXISTS admin_role;
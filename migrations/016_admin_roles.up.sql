@@ -0,0 +1,7 @@
+-- Admin roles decide whether personal data is masked in admin endpoints.
+-- Existing admins keep full visibility; new admins start masked until a role
+-- is granted.
+
+ALTER TABLE customer_schema.users ADD COLUMN IF NOT EXISTS admin_role VARCHAR(32) NOT NULL DEFAULT '';
+
+UPDATE customer_schema.users SET admin_role = 'superadmin' WHERE user_type = 'admin' AND admin_role = '';
@@ -0,0 +1,5 @@
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_transaction_type_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_transaction_type_check CHECK (transaction_type IN (
+    'payment', 'transfer', 'withdrawal', 'deposit',
+    'refund', 'reversal', 'settlement'
+));
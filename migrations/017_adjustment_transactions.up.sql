@@ -0,0 +1,8 @@
+-- Compensating transactions written by the ledger replay tool (cmd/replay)
+-- when a stored wallet balance has drifted from its ledger entries.
+
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_transaction_type_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_transaction_type_check CHECK (transaction_type IN (
+    'payment', 'transfer', 'withdrawal', 'deposit',
+    'refund', 'reversal', 'settlement', 'adjustment'
+));
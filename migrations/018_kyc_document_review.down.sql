@@ -0,0 +1,11 @@
+UPDATE customer_schema.kyc_documents SET replaces_id = NULL;
+DELETE FROM customer_schema.kyc_documents WHERE superseded_at IS NOT NULL;
+DROP INDEX IF EXISTS customer_schema.idx_kyc_documents_replaces;
+DROP INDEX IF EXISTS customer_schema.uq_kyc_documents_current;
+ALTER TABLE customer_schema.kyc_documents ADD CONSTRAINT kyc_documents_user_id_document_type_document_number_key
+    UNIQUE (user_id, document_type, document_number);
+ALTER TABLE customer_schema.kyc_documents
+    DROP COLUMN IF EXISTS superseded_at,
+    DROP COLUMN IF EXISTS replaces_id,
+    DROP COLUMN IF EXISTS rejection_reason,
+    DROP COLUMN IF EXISTS review_status;
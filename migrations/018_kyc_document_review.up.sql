@@ -0,0 +1,23 @@
+-- Per-document KYC review progress. A re-upload is a new row that points at
+-- the attempt it replaces; the old row is kept, marked superseded, so the
+-- history of attempts survives. Only current attempts must be unique.
+
+ALTER TABLE customer_schema.kyc_documents
+    ADD COLUMN IF NOT EXISTS review_status VARCHAR(20) NOT NULL DEFAULT 'uploaded',
+    ADD COLUMN IF NOT EXISTS rejection_reason TEXT,
+    ADD COLUMN IF NOT EXISTS replaces_id UUID REFERENCES customer_schema.kyc_documents(id),
+    ADD COLUMN IF NOT EXISTS superseded_at TIMESTAMPTZ;
+
+UPDATE customer_schema.kyc_documents SET
+    review_status = CASE verification_status
+        WHEN 'verified' THEN 'accepted'
+        WHEN 'rejected' THEN 'rejected'
+        ELSE 'in_review'
+    END,
+    rejection_reason = CASE WHEN verification_status = 'rejected' THEN verification_notes END;
+
+ALTER TABLE customer_schema.kyc_documents DROP CONSTRAINT IF EXISTS kyc_documents_user_id_document_type_document_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_kyc_documents_current
+    ON customer_schema.kyc_documents(user_id, document_type, document_number)
+    WHERE superseded_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_kyc_documents_replaces ON customer_schema.kyc_documents(replaces_id);
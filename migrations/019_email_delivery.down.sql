@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_schema.email_suppressions;
+DROP TABLE IF EXISTS customer_schema.email_messages;
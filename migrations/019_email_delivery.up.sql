@@ -0,0 +1,35 @@
+-- Delivery tracking for outgoing email. Recipient and body are encrypted;
+-- recipient_index is a blind index so messages and suppressions can be
+-- looked up by address. Bodies are kept so transient failures can be retried.
+
+CREATE TABLE IF NOT EXISTS customer_schema.email_messages (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(40) NOT NULL DEFAULT '',
+    critical BOOLEAN NOT NULL DEFAULT FALSE,
+    recipient TEXT NOT NULL,
+    recipient_index VARCHAR(64) NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('queued', 'sent', 'bounced', 'failed', 'suppressed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    sent_at TIMESTAMPTZ,
+    resent_from UUID REFERENCES customer_schema.email_messages(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_messages_due
+    ON customer_schema.email_messages (next_attempt_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_email_messages_status
+    ON customer_schema.email_messages (status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_email_messages_recipient
+    ON customer_schema.email_messages (recipient_index);
+
+-- Addresses that hard-bounced. Mail to them is recorded but not sent.
+CREATE TABLE IF NOT EXISTS customer_schema.email_suppressions (
+    recipient_index VARCHAR(64) PRIMARY KEY,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
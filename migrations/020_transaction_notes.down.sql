@@ -0,0 +1 @@
+DROP TABLE IF EXISTS customer_schema.transaction_notes;
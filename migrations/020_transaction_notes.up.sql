@@ -0,0 +1,23 @@
+-- Notes on transactions. A 'private' note is a customer's own note and tags,
+-- one per author and transaction; 'internal' notes are written by support
+-- and admins, never shown to customers, and only ever appended.
+
+CREATE TABLE IF NOT EXISTS customer_schema.transaction_notes (
+    id UUID PRIMARY KEY,
+    transaction_id UUID NOT NULL REFERENCES customer_schema.transactions(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    visibility VARCHAR(10) NOT NULL CHECK (visibility IN ('private', 'internal')),
+    body TEXT NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_transaction_notes_private
+    ON customer_schema.transaction_notes (transaction_id, author_id) WHERE visibility = 'private';
+CREATE INDEX IF NOT EXISTS idx_transaction_notes_transaction
+    ON customer_schema.transaction_notes (transaction_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transaction_notes_author
+    ON customer_schema.transaction_notes (author_id) WHERE visibility = 'private';
+CREATE INDEX IF NOT EXISTS idx_transaction_notes_tags
+    ON customer_schema.transaction_notes USING GIN (tags);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS customer_schema.spending_controls;
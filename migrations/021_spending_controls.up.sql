@@ -0,0 +1,12 @@
+-- Self-service spending controls. One row per customer who has set any;
+-- customers without a row have no controls beyond the platform limits.
+
+CREATE TABLE IF NOT EXISTS customer_schema.spending_controls (
+    user_id UUID PRIMARY KEY REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    max_per_transaction DECIMAL(20,2) CHECK (max_per_transaction > 0),
+    daily_cap DECIMAL(20,2) CHECK (daily_cap > 0),
+    block_cross_border BOOLEAN NOT NULL DEFAULT FALSE,
+    blocked_channels TEXT[] NOT NULL DEFAULT '{}',
+    frozen BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,13 @@
+-- Wallets a customer trusts. Payments to them under the configured amount
+-- skip step-up verification once active_from (the end of the cooling-off
+-- period) has passed.
+
+CREATE TABLE IF NOT EXISTS customer_schema.trusted_beneficiaries (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id) ON DELETE CASCADE,
+    nickname VARCHAR(64) NOT NULL DEFAULT '',
+    active_from TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, wallet_id)
+);
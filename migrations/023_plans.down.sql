@@ -0,0 +1,2 @@
+ALTER TABLE admin_schema.api_keys DROP COLUMN IF EXISTS owner_id;
+DROP TABLE IF EXISTS customer_schema.plan_subscriptions;
@@ -0,0 +1,14 @@
+-- Plan subscriptions. Users without a row are on the consumer plan. A
+-- pending_plan is a downgrade that takes effect at period_end.
+
+CREATE TABLE IF NOT EXISTS customer_schema.plan_subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    plan VARCHAR(20) NOT NULL,
+    pending_plan VARCHAR(20),
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- API keys issued to a customer account need a plan with API access.
+ALTER TABLE admin_schema.api_keys ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES customer_schema.users(id);
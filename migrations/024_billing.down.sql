@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS customer_schema.billing_charges;
+DROP TABLE IF EXISTS customer_schema.invoice_lines;
+DROP TABLE IF EXISTS customer_schema.invoices;
+DROP TABLE IF EXISTS customer_schema.billing_usage;
@@ -0,0 +1,64 @@
+-- Monthly billing of plan fees and metered usage. Usage is summed per
+-- account, metric, currency and calendar month as it happens; charges wait
+-- for the next invoice. There is one invoice per account, month and
+-- currency.
+
+CREATE TABLE IF NOT EXISTS customer_schema.billing_usage (
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    metric VARCHAR(30) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    period_start DATE NOT NULL,
+    quantity NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, metric, currency, period_start)
+);
+
+CREATE TABLE IF NOT EXISTS customer_schema.invoices (
+    id UUID PRIMARY KEY,
+    number VARCHAR(30) NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    total NUMERIC(20, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('open', 'paid', 'past_due', 'uncollectible')),
+    collection_method VARCHAR(20) NOT NULL CHECK (collection_method IN ('wallet', 'external')),
+    due_at TIMESTAMPTZ NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    paid_at TIMESTAMPTZ,
+    payment_reference VARCHAR(100) NOT NULL DEFAULT '',
+    transaction_id UUID REFERENCES customer_schema.transactions(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, period_start, currency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_user ON customer_schema.invoices (user_id, period_start DESC);
+CREATE INDEX IF NOT EXISTS idx_invoices_collectible ON customer_schema.invoices (status, next_attempt_at)
+    WHERE status IN ('open', 'past_due');
+
+CREATE TABLE IF NOT EXISTS customer_schema.invoice_lines (
+    id UUID PRIMARY KEY,
+    invoice_id UUID NOT NULL REFERENCES customer_schema.invoices(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL,
+    description TEXT NOT NULL,
+    quantity NUMERIC(20, 2) NOT NULL,
+    unit_price NUMERIC(20, 6) NOT NULL,
+    amount NUMERIC(20, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_invoice ON customer_schema.invoice_lines (invoice_id);
+
+CREATE TABLE IF NOT EXISTS customer_schema.billing_charges (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    amount NUMERIC(20, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    description TEXT NOT NULL,
+    invoice_id UUID REFERENCES customer_schema.invoices(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_billing_charges_unbilled ON customer_schema.billing_charges (user_id)
+    WHERE invoice_id IS NULL;
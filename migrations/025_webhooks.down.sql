@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS admin_schema.webhook_deliveries;
+DROP TABLE IF EXISTS admin_schema.webhook_endpoints;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS customer_schema.idx_tx_sender_created;
+ALTER TABLE admin_schema.broadcasts DROP COLUMN IF EXISTS segment_id;
+DROP TABLE IF EXISTS admin_schema.feature_flags;
+DROP TABLE IF EXISTS admin_schema.segments;
@@ -0,0 +1,30 @@
+-- Reusable user segments. Payment fees and risk scoring read fee_rate and
+-- risk_adjustment from the segments a sender belongs to; feature flags and
+-- broadcasts refer to segments by ID.
+
+CREATE TABLE IF NOT EXISTS admin_schema.segments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    key VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    criteria JSONB NOT NULL DEFAULT '{}',
+    fee_rate NUMERIC(10, 6) CHECK (fee_rate IS NULL OR (fee_rate >= 0 AND fee_rate < 1)),
+    risk_adjustment INTEGER NOT NULL DEFAULT 0 CHECK (risk_adjustment BETWEEN -100 AND 100),
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.feature_flags (
+    key VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    segments UUID[] NOT NULL DEFAULT '{}',
+    updated_by UUID,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE admin_schema.broadcasts ADD COLUMN IF NOT EXISTS segment_id UUID REFERENCES admin_schema.segments(id) ON DELETE SET NULL;
+
+-- Volume criteria sum a sender's recent payments.
+CREATE INDEX IF NOT EXISTS idx_tx_sender_created ON customer_schema.transactions(sender_id, created_at);
@@ -0,0 +1,7 @@
+DELETE FROM admin_schema.webhook_deliveries WHERE replay_of IS NOT NULL;
+DROP INDEX IF EXISTS admin_schema.uq_webhook_deliveries_original;
+ALTER TABLE admin_schema.webhook_deliveries ADD CONSTRAINT webhook_deliveries_endpoint_id_event_id_key UNIQUE (endpoint_id, event_id);
+ALTER TABLE admin_schema.webhook_deliveries DROP COLUMN IF EXISTS replay_of;
+ALTER TABLE admin_schema.webhook_endpoints
+    DROP COLUMN IF EXISTS previous_secret_expires_at,
+    DROP COLUMN IF EXISTS previous_secret;
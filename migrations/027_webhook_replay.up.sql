@@ -0,0 +1,15 @@
+-- Webhook replay and secret rotation. A replay is a new delivery of an
+-- event already delivered, pointing at the original; only originals are
+-- unique per endpoint and event. During a rotation the previous secret
+-- keeps signing deliveries, alongside the new one, until it expires.
+
+ALTER TABLE admin_schema.webhook_endpoints
+    ADD COLUMN IF NOT EXISTS previous_secret VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMPTZ;
+
+ALTER TABLE admin_schema.webhook_deliveries
+    ADD COLUMN IF NOT EXISTS replay_of UUID REFERENCES admin_schema.webhook_deliveries(id) ON DELETE CASCADE;
+
+ALTER TABLE admin_schema.webhook_deliveries DROP CONSTRAINT IF EXISTS webhook_deliveries_endpoint_id_event_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_webhook_deliveries_original ON admin_schema.webhook_deliveries (endpoint_id, event_id)
+    WHERE replay_of IS NULL;
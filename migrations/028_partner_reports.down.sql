@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS admin_schema.report_schedules;
+DELETE FROM admin_schema.export_jobs WHERE kind IN ('settlement_report', 'fee_report');
+ALTER TABLE admin_schema.export_jobs DROP CONSTRAINT IF EXISTS export_jobs_kind_check;
+ALTER TABLE admin_schema.export_jobs ADD CONSTRAINT export_jobs_kind_check CHECK (kind IN ('audit_logs', 'transactions'));
+DROP INDEX IF EXISTS admin_schema.idx_export_jobs_owner;
+ALTER TABLE admin_schema.export_jobs DROP COLUMN IF EXISTS owner_id;
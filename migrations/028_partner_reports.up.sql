@@ -0,0 +1,25 @@
+-- Partner reports. They are export jobs owned by a merchant account, whose
+-- rows are always limited to that account's transactions, plus daily
+-- schedules that queue one report per UTC day.
+
+ALTER TABLE admin_schema.export_jobs
+    ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES customer_schema.users(id) ON DELETE CASCADE;
+
+ALTER TABLE admin_schema.export_jobs DROP CONSTRAINT IF EXISTS export_jobs_kind_check;
+ALTER TABLE admin_schema.export_jobs ADD CONSTRAINT export_jobs_kind_check
+    CHECK (kind IN ('audit_logs', 'transactions', 'settlement_report', 'fee_report'));
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_owner ON admin_schema.export_jobs(owner_id, created_at DESC)
+    WHERE owner_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS admin_schema.report_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL CHECK (kind IN ('settlement_report', 'fee_report')),
+    currency VARCHAR(10) NOT NULL DEFAULT '',
+    -- The last UTC day a report was queued for.
+    last_period DATE,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (owner_id, kind, currency)
+);
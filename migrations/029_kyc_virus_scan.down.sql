@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS customer_schema.idx_kyc_documents_virus_scan;
+
+ALTER TABLE customer_schema.kyc_documents
+    DROP COLUMN IF EXISTS scanned_at,
+    DROP COLUMN IF EXISTS virus_signature,
+    DROP COLUMN IF EXISTS virus_scan_status;
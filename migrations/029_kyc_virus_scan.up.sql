@@ -0,0 +1,12 @@
+-- Virus scanning of KYC uploads. Documents already on file were never
+-- scanned and stay reviewable as 'skipped'; new uploads start 'pending' and
+-- cannot be verified until clamd has passed them.
+
+ALTER TABLE customer_schema.kyc_documents
+    ADD COLUMN IF NOT EXISTS virus_scan_status VARCHAR(20) NOT NULL DEFAULT 'skipped',
+    ADD COLUMN IF NOT EXISTS virus_signature TEXT,
+    ADD COLUMN IF NOT EXISTS scanned_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_kyc_documents_virus_scan
+    ON customer_schema.kyc_documents(virus_scan_status)
+    WHERE virus_scan_status IN ('pending', 'failed');
@@ -0,0 +1,24 @@
+-- Sanctions and PEP screenings of users. Every screening is kept; the
+-- latest is the user's AML profile, and its risk score is copied to
+-- users.risk_score.
+
+CREATE TABLE IF NOT EXISTS customer_schema.aml_screenings (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    screened_name TEXT NOT NULL,
+    countries TEXT[] NOT NULL DEFAULT '{}',
+    sanction_hit BOOLEAN NOT NULL DEFAULT FALSE,
+    pep BOOLEAN NOT NULL DEFAULT FALSE,
+    country_hit BOOLEAN NOT NULL DEFAULT FALSE,
+    risk_score INTEGER NOT NULL DEFAULT 0,
+    matches JSONB NOT NULL DEFAULT '[]',
+    cleared_by UUID REFERENCES customer_schema.users(id),
+    cleared_at TIMESTAMPTZ,
+    clear_reason TEXT,
+    screened_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_aml_screenings_user ON customer_schema.aml_screenings(user_id, screened_at DESC);
+CREATE INDEX IF NOT EXISTS idx_aml_screenings_open_hits ON customer_schema.aml_screenings(screened_at)
+    WHERE sanction_hit AND cleared_at IS NULL;
@@ -0,0 +1,4 @@
+-- The tenant a user was onboarded under. Policies that vary by tenant, such
+-- as the email verification grace period, are keyed by it.
+
+ALTER TABLE customer_schema.users ADD COLUMN IF NOT EXISTS tenant VARCHAR(64) NOT NULL DEFAULT 'default';
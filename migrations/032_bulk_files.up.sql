@@ -0,0 +1,25 @@
+-- Payment files received from corporates through the file channel, kept
+-- as received for dispute resolution. The same file (by hash) is only ever
+-- accepted once per customer.
+
+CREATE TABLE IF NOT EXISTS customer_schema.bulk_files (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE RESTRICT,
+    file_name TEXT NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    format_version VARCHAR(20) NOT NULL DEFAULT '',
+    reference TEXT NOT NULL DEFAULT '',
+    sha256 CHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    content BYTEA NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    payment_count INTEGER NOT NULL DEFAULT 0,
+    errors JSONB,
+    result JSONB,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bulk_files_accepted_hash ON customer_schema.bulk_files(user_id, sha256)
+    WHERE status <> 'rejected';
+CREATE INDEX IF NOT EXISTS idx_bulk_files_user ON customer_schema.bulk_files(user_id, received_at DESC);
@@ -0,0 +1,23 @@
+DROP INDEX IF EXISTS customer_schema.idx_tx_queued_for_next_window;
+
+-- Release or cancel queued payments first: any left are cancelled here, and
+-- the funds reserved for them must then be released by hand.
+UPDATE customer_schema.transactions SET status = 'cancelled' WHERE status = 'queued_for_next_window';
+
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_status_check CHECK (status IN (
+    'pending',
+    'processing',
+    'reserved',
+    'settling',
+    'completed',
+    'failed',
+    'cancelled',
+    'refunded',
+    'disputed',
+    'reversed',
+    'pending_approval',
+    'pending_settlement',
+    'requires_review',
+    'admin_investigation'
+));
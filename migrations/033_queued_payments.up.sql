@@ -0,0 +1,25 @@
+-- Cross-border payments initiated after their corridor's cut-off wait in
+-- queued_for_next_window until the window opens (metadata queued_until).
+
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_status_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_status_check CHECK (status IN (
+    'pending',
+    'processing',
+    'reserved',
+    'settling',
+    'completed',
+    'failed',
+    'cancelled',
+    'refunded',
+    'disputed',
+    'reversed',
+    'pending_approval',
+    'pending_settlement',
+    'requires_review',
+    'admin_investigation',
+    'queued_for_next_window'
+));
+
+CREATE INDEX IF NOT EXISTS idx_tx_queued_for_next_window
+    ON customer_schema.transactions (created_at)
+    WHERE status = 'queued_for_next_window';
@@ -0,0 +1,23 @@
+-- Audit of every net settlement window: the bilateral position of a corridor
+-- and a snapshot of each payment netted into it. transactions holds the
+-- snapshot as a JSON array.
+
+CREATE TABLE IF NOT EXISTS customer_schema.settlement_nettings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    settlement_id UUID NOT NULL UNIQUE REFERENCES customer_schema.settlements(id),
+    corridor VARCHAR(7) NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    window_end TIMESTAMPTZ NOT NULL,
+    quote_currency VARCHAR(3) NOT NULL,
+    outbound DECIMAL(20, 2) NOT NULL,
+    inbound DECIMAL(20, 2) NOT NULL,
+    net_position DECIMAL(20, 2) NOT NULL,
+    settlement_amount DECIMAL(20, 2) NOT NULL CHECK (settlement_amount >= 0),
+    settlement_currency VARCHAR(3) NOT NULL,
+    transaction_count INTEGER NOT NULL,
+    transactions JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (window_end > window_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_nettings_corridor ON customer_schema.settlement_nettings(corridor, window_start);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS admin_schema.counterparty_prefunding;
+DROP TABLE IF EXISTS admin_schema.counterparty_balances;
+DROP TABLE IF EXISTS admin_schema.counterparty_documents;
+DROP TABLE IF EXISTS admin_schema.counterparties;
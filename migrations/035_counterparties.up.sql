@@ -0,0 +1,57 @@
+-- Settlement partners, payout providers and correspondent banks, with their
+-- compliance documents and prefunded balances. Every balance movement is
+-- kept in counterparty_prefunding with the balance it left.
+
+CREATE TABLE IF NOT EXISTS admin_schema.counterparties (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(30) NOT NULL CHECK (type IN ('settlement_partner', 'payout_provider', 'correspondent_bank')),
+    country VARCHAR(2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended')),
+    status_reason TEXT NOT NULL DEFAULT '',
+    corridors TEXT[] NOT NULL DEFAULT '{}',
+    exposure_limit DECIMAL(20, 2) CHECK (exposure_limit IS NULL OR exposure_limit > 0),
+    exposure_currency VARCHAR(3) NOT NULL DEFAULT '',
+    contacts JSONB NOT NULL DEFAULT '[]',
+    notes TEXT NOT NULL DEFAULT '',
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.counterparty_documents (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    counterparty_id UUID NOT NULL REFERENCES admin_schema.counterparties(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    uploaded_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_counterparty_documents_counterparty ON admin_schema.counterparty_documents(counterparty_id);
+
+CREATE TABLE IF NOT EXISTS admin_schema.counterparty_balances (
+    counterparty_id UUID NOT NULL REFERENCES admin_schema.counterparties(id),
+    currency VARCHAR(3) NOT NULL,
+    balance DECIMAL(20, 2) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (counterparty_id, currency)
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.counterparty_prefunding (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    counterparty_id UUID NOT NULL REFERENCES admin_schema.counterparties(id),
+    currency VARCHAR(3) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('deposit', 'drawdown', 'return', 'withdrawal')),
+    amount DECIMAL(20, 2) NOT NULL,
+    balance_after DECIMAL(20, 2) NOT NULL,
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    settlement_id UUID,
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_counterparty_prefunding_counterparty ON admin_schema.counterparty_prefunding(counterparty_id, created_at DESC);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS customer_schema.idx_settlements_counterparty_outstanding;
@@ -0,0 +1,7 @@
+-- Exposure to a counterparty is the value of its settlements that have drawn
+-- prefunding but not yet completed; this index keeps summing them cheap.
+
+CREATE INDEX IF NOT EXISTS idx_settlements_counterparty_outstanding
+    ON customer_schema.settlements ((metadata->>'counterparty_id'), currency)
+    WHERE status IN ('pending', 'processing', 'submitted')
+      AND metadata @> '{"prefunding_drawn": true}';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS customer_schema.idx_kyc_documents_current_review;
+DROP TABLE IF EXISTS customer_schema.kyc_reviews;
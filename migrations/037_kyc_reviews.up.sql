@@ -0,0 +1,18 @@
+-- Reviewer decisions on KYC profiles: approvals, rejections and requests
+-- for more information, each with the reviewer and their notes.
+
+CREATE TABLE IF NOT EXISTS customer_schema.kyc_reviews (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    reviewer_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    decision VARCHAR(20) NOT NULL CHECK (decision IN ('approve', 'reject', 'request_info')),
+    notes TEXT,
+    reviewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_kyc_reviews_user ON customer_schema.kyc_reviews(user_id, reviewed_at DESC);
+
+-- The review queues look up current documents by review status.
+CREATE INDEX IF NOT EXISTS idx_kyc_documents_current_review
+    ON customer_schema.kyc_documents(review_status, user_id)
+    WHERE superseded_at IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS customer_schema.idx_ledger_entries_transaction;
+DROP TABLE IF EXISTS admin_schema.integrity_runs;
+DROP TABLE IF EXISTS admin_schema.integrity_findings;
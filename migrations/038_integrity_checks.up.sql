@@ -0,0 +1,36 @@
+-- Findings of the scheduled integrity checks, and a record of each run. A
+-- problem stays one open finding for as long as runs keep seeing it.
+
+CREATE TABLE IF NOT EXISTS admin_schema.integrity_findings (
+    id UUID PRIMARY KEY,
+    check_name VARCHAR(50) NOT NULL,
+    severity VARCHAR(20) NOT NULL CHECK (severity IN ('low', 'medium', 'high', 'critical')),
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_integrity_findings_open
+    ON admin_schema.integrity_findings(check_name, resource_type, resource_id)
+    WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_integrity_findings_status
+    ON admin_schema.integrity_findings(status, last_seen_at DESC);
+
+CREATE TABLE IF NOT EXISTS admin_schema.integrity_runs (
+    id UUID PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL,
+    open_findings INTEGER NOT NULL DEFAULT 0,
+    opened INTEGER NOT NULL DEFAULT 0,
+    resolved INTEGER NOT NULL DEFAULT 0,
+    failed TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_integrity_runs_started ON admin_schema.integrity_runs(started_at DESC);
+
+-- Completed transactions are checked for ledger entries.
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction ON customer_schema.ledger_entries(transaction_id);
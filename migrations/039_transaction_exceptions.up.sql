@@ -0,0 +1,21 @@
+-- Stuck transactions that automated recovery could not fix, queued for an
+-- admin. A transaction has at most one open exception.
+
+CREATE TABLE IF NOT EXISTS admin_schema.transaction_exceptions (
+    id UUID PRIMARY KEY,
+    transaction_id UUID NOT NULL,
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    reason VARCHAR(50) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    resolution TEXT,
+    resolved_by UUID,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_transaction_exceptions_open
+    ON admin_schema.transaction_exceptions(transaction_id)
+    WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_transaction_exceptions_status
+    ON admin_schema.transaction_exceptions(status, created_at DESC);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS customer_schema.journal_lines;
+DROP FUNCTION IF EXISTS customer_schema.check_journal_balanced();
+DROP FUNCTION IF EXISTS customer_schema.reject_journal_change();
+DROP TABLE IF EXISTS customer_schema.ledger_house_users;
+DROP TABLE IF EXISTS customer_schema.ledger_accounts;
@@ -0,0 +1,94 @@
+-- Double-entry general ledger. Every posting that moves wallet money also
+-- writes a journal: lines against the chart of accounts whose debits equal
+-- their credits in every currency. Wallet lines go to customer_wallets, or
+-- to the account of a house user such as the fee collector.
+
+CREATE TABLE IF NOT EXISTS customer_schema.ledger_accounts (
+    code VARCHAR(10) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    account_type VARCHAR(20) NOT NULL CHECK (account_type IN ('asset', 'liability', 'equity', 'income', 'expense')),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO customer_schema.ledger_accounts (code, name, account_type, description) VALUES
+    ('1010', 'settlement_clearing', 'asset', 'Cross-currency payments between leaving one currency and arriving in another'),
+    ('1020', 'provider_clearing', 'asset', 'Money held at top-up and payout providers'),
+    ('2010', 'customer_wallets', 'liability', 'What the platform owes wallet holders'),
+    ('3010', 'opening_balances', 'equity', 'Wallet balances brought forward when the general ledger started'),
+    ('4010', 'fee_income', 'income', 'Fees charged, including what the fee collector''s wallets hold'),
+    ('4020', 'fx_gain_loss', 'income', 'Spread between the mid rate and the rate customers converted at')
+ON CONFLICT (code) DO NOTHING;
+
+-- Users whose wallets are the platform's own money rather than a liability.
+CREATE TABLE IF NOT EXISTS customer_schema.ledger_house_users (
+    user_id UUID PRIMARY KEY REFERENCES customer_schema.users(id),
+    account_code VARCHAR(10) NOT NULL REFERENCES customer_schema.ledger_accounts(code),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS customer_schema.journal_lines (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    journal_id UUID NOT NULL,
+    transaction_id UUID,
+    account_code VARCHAR(10) NOT NULL REFERENCES customer_schema.ledger_accounts(code),
+    wallet_id UUID,
+    currency VARCHAR(3) NOT NULL,
+    debit DECIMAL(20,2) NOT NULL DEFAULT 0,
+    credit DECIMAL(20,2) NOT NULL DEFAULT 0,
+    memo VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((debit > 0 AND credit = 0) OR (credit > 0 AND debit = 0))
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_lines_journal ON customer_schema.journal_lines(journal_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_transaction ON customer_schema.journal_lines(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_account ON customer_schema.journal_lines(account_code, currency);
+
+-- A journal must balance per currency by the time its transaction commits.
+CREATE OR REPLACE FUNCTION customer_schema.check_journal_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM customer_schema.journal_lines
+        WHERE journal_id = NEW.journal_id
+        GROUP BY currency
+        HAVING SUM(debit) <> SUM(credit)
+    ) THEN
+        RAISE EXCEPTION 'journal % does not balance', NEW.journal_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_lines_balanced ON customer_schema.journal_lines;
+CREATE CONSTRAINT TRIGGER journal_lines_balanced
+    AFTER INSERT ON customer_schema.journal_lines
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION customer_schema.check_journal_balanced();
+
+-- Journals are corrected with new journals, never edited.
+CREATE OR REPLACE FUNCTION customer_schema.reject_journal_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'journal lines are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_lines_immutable ON customer_schema.journal_lines;
+CREATE TRIGGER journal_lines_immutable
+    BEFORE UPDATE OR DELETE ON customer_schema.journal_lines
+    FOR EACH ROW EXECUTE FUNCTION customer_schema.reject_journal_change();
+
+-- Bring existing wallet balances onto the books.
+WITH journal AS (
+    SELECT uuid_generate_v4() AS id
+), totals AS (
+    SELECT currency, SUM(ledger_balance) AS balance
+    FROM customer_schema.wallets
+    GROUP BY currency
+    HAVING SUM(ledger_balance) > 0
+)
+INSERT INTO customer_schema.journal_lines (journal_id, account_code, currency, debit, credit, memo)
+SELECT journal.id, line.account_code, totals.currency, line.debit, line.credit, 'opening_balance'
+FROM journal, totals,
+    LATERAL (VALUES ('3010', totals.balance, 0), ('2010', 0, totals.balance)) AS line(account_code, debit, credit)
+WHERE NOT EXISTS (SELECT 1 FROM customer_schema.journal_lines WHERE memo = 'opening_balance');
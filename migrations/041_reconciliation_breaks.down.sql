@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS customer_schema.idx_ledger_entries_wallet_type;
+DROP TABLE IF EXISTS admin_schema.reconciliation_breaks;
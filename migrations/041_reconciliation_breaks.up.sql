@@ -0,0 +1,27 @@
+-- Discrepancies found by scheduled reconciliation. A break stays one open
+-- row for as long as runs keep seeing it and is resolved by the first run
+-- that does not.
+
+CREATE TABLE IF NOT EXISTS admin_schema.reconciliation_breaks (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    subject TEXT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    expected DECIMAL(20,2) NOT NULL,
+    actual DECIMAL(20,2) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_reconciliation_breaks_open
+    ON admin_schema.reconciliation_breaks(kind, subject)
+    WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_reconciliation_breaks_status
+    ON admin_schema.reconciliation_breaks(status, last_seen_at DESC);
+
+-- Wallets are reconciled against the sum of their entries.
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_wallet_type
+    ON customer_schema.ledger_entries(wallet_id, entry_type);
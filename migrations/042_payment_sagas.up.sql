@@ -0,0 +1,23 @@
+-- Progress of each payment initiation, written before the transaction row
+-- exists and advanced after every step that moves money, so that a payment
+-- interrupted by a crash can be completed or compensated on recovery.
+
+CREATE TABLE IF NOT EXISTS customer_schema.payment_sagas (
+    transaction_id UUID PRIMARY KEY,
+    reference VARCHAR(100) NOT NULL,
+    sender_wallet_id UUID NOT NULL,
+    total_debit DECIMAL(20,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    step VARCHAR(20) NOT NULL DEFAULT 'started'
+        CHECK (step IN ('started', 'recorded', 'reserved', 'posted')),
+    state VARCHAR(20) NOT NULL DEFAULT 'running'
+        CHECK (state IN ('running', 'completed', 'compensated')),
+    outcome TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_sagas_running
+    ON customer_schema.payment_sagas(updated_at)
+    WHERE state = 'running';
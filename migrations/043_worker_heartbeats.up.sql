@@ -0,0 +1,22 @@
+-- Liveness of background workers. Each process flushes the last beat of
+-- every worker it runs; a row whose beat stops advancing is a worker that
+-- stalled or a process that died.
+
+CREATE TABLE IF NOT EXISTS admin_schema.worker_heartbeats (
+    service VARCHAR(50) NOT NULL,
+    worker VARCHAR(100) NOT NULL,
+    instance VARCHAR(255) NOT NULL,
+    interval_seconds INTEGER NOT NULL,
+    restartable BOOLEAN NOT NULL DEFAULT FALSE,
+    restarts INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL,
+    last_beat_at TIMESTAMPTZ NOT NULL,
+    stopped_at TIMESTAMPTZ,
+    alerted_at TIMESTAMPTZ,
+    alerted_restarts INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (service, worker, instance)
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_heartbeats_updated
+    ON admin_schema.worker_heartbeats(updated_at);
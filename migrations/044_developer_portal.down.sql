@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS admin_schema.api_key_errors;
+DROP TABLE IF EXISTS admin_schema.api_key_usage;
+DROP TABLE IF EXISTS customer_schema.developer_environments;
+DROP INDEX IF EXISTS admin_schema.idx_api_keys_owner;
+ALTER TABLE admin_schema.api_keys DROP COLUMN IF EXISTS environment;
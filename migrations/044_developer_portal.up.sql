@@ -0,0 +1,37 @@
+-- Developer portal data. API keys belong to the production or sandbox
+-- environment, which their owner can switch off. Calls made with a key are
+-- counted per day, and the latest failed calls are kept as samples.
+
+ALTER TABLE admin_schema.api_keys ADD COLUMN IF NOT EXISTS environment VARCHAR(20) NOT NULL DEFAULT 'production'
+    CHECK (environment IN ('production', 'sandbox'));
+CREATE INDEX IF NOT EXISTS idx_api_keys_owner ON admin_schema.api_keys(owner_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS customer_schema.developer_environments (
+    user_id UUID PRIMARY KEY REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    production_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    sandbox_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.api_key_usage (
+    api_key_id UUID NOT NULL REFERENCES admin_schema.api_keys(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    client_errors BIGINT NOT NULL DEFAULT 0,
+    server_errors BIGINT NOT NULL DEFAULT 0,
+    total_latency_ms BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.api_key_errors (
+    id UUID PRIMARY KEY,
+    api_key_id UUID NOT NULL REFERENCES admin_schema.api_keys(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INT NOT NULL,
+    request_id VARCHAR(100) NOT NULL DEFAULT '',
+    response_body TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_key_errors_key ON admin_schema.api_key_errors(api_key_id, occurred_at DESC);
@@ -0,0 +1,25 @@
+-- Transactional outbox. Domain events are written in the same database
+-- transaction as the change they describe and published to the message
+-- broker afterwards, so an event is sent if and only if its change
+-- committed.
+
+CREATE TABLE IF NOT EXISTS admin_schema.outbox_events (
+    id UUID PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending
+    ON admin_schema.outbox_events(next_attempt_at)
+    WHERE published_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published
+    ON admin_schema.outbox_events(published_at)
+    WHERE published_at IS NOT NULL;
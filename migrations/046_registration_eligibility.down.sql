@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS admin_schema.eligibility_rejections;
+DROP TABLE IF EXISTS admin_schema.eligibility_rules;
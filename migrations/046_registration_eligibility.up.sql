@@ -0,0 +1,39 @@
+-- Per-country registration eligibility rules, and registrations they
+-- refused. A refused applicant may appeal once; an approved appeal lets the
+-- same email register in that country.
+
+CREATE TABLE IF NOT EXISTS admin_schema.eligibility_rules (
+    country_code VARCHAR(2) PRIMARY KEY,
+    min_age SMALLINT NOT NULL DEFAULT 0 CHECK (min_age BETWEEN 0 AND 120),
+    require_date_of_birth BOOLEAN NOT NULL DEFAULT FALSE,
+    restricted_nationalities TEXT[] NOT NULL DEFAULT '{}',
+    restricted_user_types TEXT[] NOT NULL DEFAULT '{}',
+    restricted_business_types TEXT[] NOT NULL DEFAULT '{}',
+    updated_by UUID,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.eligibility_rejections (
+    id UUID PRIMARY KEY,
+    email TEXT NOT NULL,
+    email_hash VARCHAR(255) NOT NULL,
+    country_code VARCHAR(2) NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    date_of_birth DATE,
+    nationality VARCHAR(2) NOT NULL DEFAULT '',
+    business_type VARCHAR(50) NOT NULL DEFAULT '',
+    reasons JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'rejected' CHECK (status IN ('rejected', 'appealed', 'approved', 'denied')),
+    appeal_statement TEXT,
+    appealed_at TIMESTAMPTZ,
+    reviewed_by UUID,
+    review_note TEXT,
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_eligibility_rejections_status
+    ON admin_schema.eligibility_rejections(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_eligibility_rejections_approved
+    ON admin_schema.eligibility_rejections(email_hash, country_code)
+    WHERE status = 'approved';
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS customer_schema.idx_wallets_user_last_transaction;
+DROP TABLE IF EXISTS customer_schema.escheatments;
+DROP TABLE IF EXISTS customer_schema.dormant_accounts;
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_transaction_type_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_transaction_type_check CHECK (transaction_type IN (
+    'payment', 'transfer', 'withdrawal', 'deposit',
+    'refund', 'reversal', 'settlement', 'adjustment'
+));
+DELETE FROM customer_schema.ledger_accounts WHERE code = '2020';
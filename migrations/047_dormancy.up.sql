@@ -0,0 +1,56 @@
+-- Dormant accounts: customers without activity for the dormancy period are
+-- warned, then their wallets are frozen, and after the escheatment period
+-- their balances move to the unclaimed balances account. A returning
+-- customer is reactivated and, once an admin approves, gets the escheated
+-- balances back.
+
+INSERT INTO customer_schema.ledger_accounts (code, name, account_type, description) VALUES
+    ('2020', 'unclaimed_balances', 'liability', 'Escheated balances of dormant wallets, held for their owners or the regulator')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_transaction_type_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_transaction_type_check CHECK (transaction_type IN (
+    'payment', 'transfer', 'withdrawal', 'deposit',
+    'refund', 'reversal', 'settlement', 'adjustment', 'escheatment'
+));
+
+CREATE TABLE IF NOT EXISTS customer_schema.dormant_accounts (
+    user_id UUID PRIMARY KEY REFERENCES customer_schema.users(id),
+    country_code VARCHAR(2) NOT NULL DEFAULT '',
+    status VARCHAR(30) NOT NULL CHECK (status IN ('notified', 'dormant', 'escheated', 'reactivation_requested', 'reactivated')),
+    last_activity_at TIMESTAMPTZ NOT NULL,
+    notices_sent SMALLINT NOT NULL DEFAULT 0,
+    last_notice_at TIMESTAMPTZ,
+    dormant_at TIMESTAMPTZ,
+    escheatment_notice_at TIMESTAMPTZ,
+    escheated_at TIMESTAMPTZ,
+    frozen_wallets TEXT[] NOT NULL DEFAULT '{}',
+    reactivated_at TIMESTAMPTZ,
+    reactivated_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dormant_accounts_status
+    ON customer_schema.dormant_accounts(status, dormant_at);
+
+CREATE TABLE IF NOT EXISTS customer_schema.escheatments (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id),
+    country_code VARCHAR(2) NOT NULL DEFAULT '',
+    amount DECIMAL(20,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'held' CHECK (status IN ('held', 'returned')),
+    transaction_id UUID NOT NULL REFERENCES customer_schema.transactions(id),
+    return_transaction_id UUID REFERENCES customer_schema.transactions(id),
+    escheated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    returned_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_escheatments_user ON customer_schema.escheatments(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_escheatments_escheated ON customer_schema.escheatments(escheated_at);
+
+-- Finds the last activity of customers quickly.
+CREATE INDEX IF NOT EXISTS idx_wallets_user_last_transaction
+    ON customer_schema.wallets(user_id, last_transaction_at);
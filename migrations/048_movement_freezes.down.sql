@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS admin_schema.movement_freeze_events;
+DROP TABLE IF EXISTS admin_schema.movement_freezes;
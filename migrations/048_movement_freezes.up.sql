@@ -0,0 +1,38 @@
+-- Emergency money movement freezes. One admin proposes a freeze (or its
+-- lift) and a different admin approves it; every step is kept in the
+-- events table.
+
+CREATE TABLE IF NOT EXISTS admin_schema.movement_freezes (
+    id UUID PRIMARY KEY,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('all', 'cross_border', 'corridor', 'withdrawals')),
+    corridor VARCHAR(7) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending_approval', 'active', 'rejected', 'lifted')),
+    reason TEXT NOT NULL,
+    message TEXT NOT NULL,
+    requested_by UUID NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    approved_by UUID,
+    activated_at TIMESTAMPTZ,
+    rejected_by UUID,
+    lift_requested_by UUID,
+    lift_requested_at TIMESTAMPTZ,
+    lifted_by UUID,
+    lifted_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((scope = 'corridor') = (corridor <> ''))
+);
+
+CREATE INDEX IF NOT EXISTS idx_movement_freezes_status
+    ON admin_schema.movement_freezes(status, requested_at DESC);
+
+CREATE TABLE IF NOT EXISTS admin_schema.movement_freeze_events (
+    id UUID PRIMARY KEY,
+    freeze_id UUID NOT NULL REFERENCES admin_schema.movement_freezes(id),
+    action VARCHAR(20) NOT NULL,
+    actor_id UUID NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_movement_freeze_events_freeze
+    ON admin_schema.movement_freeze_events(freeze_id, created_at);
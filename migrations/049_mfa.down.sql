@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS admin_schema.mfa_policy;
+DROP TABLE IF EXISTS customer_schema.mfa_backup_codes;
+DROP TABLE IF EXISTS customer_schema.mfa_codes;
@@ -0,0 +1,33 @@
+-- Two-factor login: SMS and email login codes, single-use backup codes and
+-- the admin policy forcing MFA on some accounts.
+
+CREATE TABLE IF NOT EXISTS customer_schema.mfa_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    method VARCHAR(20) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_codes_user_id ON customer_schema.mfa_codes(user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS customer_schema.mfa_backup_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- One row: the policy in force once an admin has set it.
+CREATE TABLE IF NOT EXISTS admin_schema.mfa_policy (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    required_for_admins BOOLEAN NOT NULL DEFAULT FALSE,
+    required_kyc_level INTEGER NOT NULL DEFAULT 0,
+    updated_by UUID,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
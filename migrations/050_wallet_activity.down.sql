@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS customer_schema.idx_transactions_updated_at;
+DROP TABLE IF EXISTS customer_schema.wallet_activity;
@@ -0,0 +1,35 @@
+-- Wallet activity feed: one row per wallet a transaction touched, written by
+-- the activity projector so wallet history is read from a single index
+-- without joins. Counterparty names are encrypted as in users.
+
+CREATE TABLE IF NOT EXISTS customer_schema.wallet_activity (
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id) ON DELETE CASCADE,
+    transaction_id UUID NOT NULL REFERENCES customer_schema.transactions(id) ON DELETE CASCADE,
+    direction VARCHAR(10) NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount DECIMAL(20, 8) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    fee_amount DECIMAL(20, 8) NOT NULL DEFAULT 0,
+    counterparty_id UUID,
+    counterparty_name TEXT NOT NULL DEFAULT '',
+    counterparty_wallet_number VARCHAR(255) NOT NULL DEFAULT '',
+    transaction_type VARCHAR(50) NOT NULL,
+    status VARCHAR(30) NOT NULL,
+    reference_number VARCHAR(20) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL,
+    -- The transaction's updated_at when projected; older versions never
+    -- overwrite newer ones.
+    source_updated_at TIMESTAMPTZ NOT NULL,
+    projected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, transaction_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_activity_feed
+    ON customer_schema.wallet_activity(wallet_id, occurred_at DESC, transaction_id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_activity_source_updated_at
+    ON customer_schema.wallet_activity(source_updated_at);
+
+-- The projector's catch-up walks transactions by last change.
+CREATE INDEX IF NOT EXISTS idx_transactions_updated_at
+    ON customer_schema.transactions(updated_at, id);
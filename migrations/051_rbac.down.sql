@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS admin_schema.user_roles;
+DROP TABLE IF EXISTS admin_schema.role_permissions;
+DROP TABLE IF EXISTS admin_schema.roles;
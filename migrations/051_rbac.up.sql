@@ -0,0 +1,61 @@
+-- Role-based access control for staff. Roles grant permissions on groups of
+-- admin endpoints; a user's permissions are the union of their roles.
+-- Existing admins keep what their admin_role let them see.
+
+CREATE TABLE IF NOT EXISTS admin_schema.roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.role_permissions (
+    role VARCHAR(32) NOT NULL REFERENCES admin_schema.roles(name) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS admin_schema.user_roles (
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    role VARCHAR(32) NOT NULL REFERENCES admin_schema.roles(name),
+    granted_by UUID REFERENCES customer_schema.users(id) ON DELETE SET NULL,
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON admin_schema.user_roles(role);
+
+INSERT INTO admin_schema.roles (name, description) VALUES
+    ('admin', 'Full access, including granting roles'),
+    ('compliance_officer', 'KYC, screening, cases and transaction review, with personal data unmasked'),
+    ('support', 'Read-only access to customers and their transactions, with personal data masked'),
+    ('treasury', 'Ledger, settlement, banking and liquidity operations')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO admin_schema.role_permissions (role, permission) VALUES
+    ('admin', '*'),
+    ('compliance_officer', 'users:read'),
+    ('compliance_officer', 'users:personal_data'),
+    ('compliance_officer', 'compliance:read'),
+    ('compliance_officer', 'compliance:write'),
+    ('compliance_officer', 'transactions:read'),
+    ('compliance_officer', 'transactions:write'),
+    ('compliance_officer', 'analytics:read'),
+    ('support', 'users:read'),
+    ('support', 'compliance:read'),
+    ('support', 'transactions:read'),
+    ('support', 'messaging:read'),
+    ('treasury', 'treasury:read'),
+    ('treasury', 'treasury:write'),
+    ('treasury', 'transactions:read'),
+    ('treasury', 'analytics:read')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO admin_schema.user_roles (user_id, role)
+SELECT id, CASE admin_role
+        WHEN 'compliance' THEN 'compliance_officer'
+        WHEN 'support' THEN 'support'
+        ELSE 'admin'
+    END
+FROM customer_schema.users
+WHERE user_type = 'admin'
+ON CONFLICT DO NOTHING;
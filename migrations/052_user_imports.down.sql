@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS admin_schema.user_import_rows;
+DROP TABLE IF EXISTS admin_schema.user_imports;
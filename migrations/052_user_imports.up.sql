@@ -0,0 +1,38 @@
+-- Bulk user imports: staff upload a CSV of people, such as a corporate
+-- customer's employees, and each valid row becomes a provisional account
+-- whose holder is invited by email to set a password. Rows keep their
+-- validation errors and outcome; their personal data is stored encrypted.
+
+CREATE TABLE IF NOT EXISTS admin_schema.user_imports (
+    id UUID PRIMARY KEY,
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    organization VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('queued', 'processing', 'completed')),
+    total_rows INT NOT NULL DEFAULT 0,
+    invalid_rows INT NOT NULL DEFAULT 0,
+    processed_rows INT NOT NULL DEFAULT 0,
+    invited_rows INT NOT NULL DEFAULT 0,
+    failed_rows INT NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES customer_schema.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_imports_status ON admin_schema.user_imports(status, created_at);
+
+CREATE TABLE IF NOT EXISTS admin_schema.user_import_rows (
+    import_id UUID NOT NULL REFERENCES admin_schema.user_imports(id) ON DELETE CASCADE,
+    line INT NOT NULL,
+    record TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('invalid', 'pending', 'invited', 'failed')),
+    errors JSONB,
+    -- Reserved on upload for valid rows, so an import resumed after a crash
+    -- recognises the accounts it already created
+    user_id UUID,
+    processed_at TIMESTAMPTZ,
+    PRIMARY KEY (import_id, line)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_import_rows_status ON admin_schema.user_import_rows(import_id, status, line);
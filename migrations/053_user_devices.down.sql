@@ -0,0 +1,2 @@
+ALTER TABLE customer_schema.user_devices DROP COLUMN IF EXISTS label;
+ALTER TABLE customer_schema.user_devices ALTER COLUMN is_trusted SET DEFAULT TRUE;
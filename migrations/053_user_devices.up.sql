@@ -0,0 +1,6 @@
+-- Devices become trusted only after the user proves a second factor on
+-- them, not merely by signing in. Users may also give a device a name.
+-- Devices already on record keep their trust.
+
+ALTER TABLE customer_schema.user_devices ALTER COLUMN is_trusted SET DEFAULT FALSE;
+ALTER TABLE customer_schema.user_devices ADD COLUMN IF NOT EXISTS label VARCHAR(100);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS customer_schema.data_sharing_access_log;
+DROP TABLE IF EXISTS customer_schema.data_sharing_consents;
+DROP TABLE IF EXISTS admin_schema.data_partners;
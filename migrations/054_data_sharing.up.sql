@@ -0,0 +1,45 @@
+-- Consent-based data sharing: users let credit-scoring partners read a
+-- standardized summary of their transactions to access loans. Partners are
+-- registered by staff and call the partner API with a key stored hashed.
+-- Each consent grants some scopes of the summary until it expires or the
+-- user revokes it, and every read is logged for the user to see.
+
+CREATE TABLE IF NOT EXISTS admin_schema.data_partners (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    key_prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID REFERENCES customer_schema.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS customer_schema.data_sharing_consents (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    partner_id UUID NOT NULL REFERENCES admin_schema.data_partners(id),
+    scopes TEXT[] NOT NULL,
+    lookback_months SMALLINT NOT NULL CHECK (lookback_months BETWEEN 1 AND 24),
+    partner_reference VARCHAR(100) NOT NULL DEFAULT '',
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_sharing_consents_user ON customer_schema.data_sharing_consents(user_id, granted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_sharing_consents_partner ON customer_schema.data_sharing_consents(partner_id, granted_at DESC);
+
+CREATE TABLE IF NOT EXISTS customer_schema.data_sharing_access_log (
+    id UUID PRIMARY KEY,
+    consent_id UUID NOT NULL REFERENCES customer_schema.data_sharing_consents(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    partner_id UUID NOT NULL,
+    scopes TEXT[] NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    accessed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_sharing_access_user ON customer_schema.data_sharing_access_log(user_id, accessed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_sharing_access_consent ON customer_schema.data_sharing_access_log(consent_id, accessed_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_schema.notification_preferences;
+DROP TABLE IF EXISTS customer_schema.push_tokens;
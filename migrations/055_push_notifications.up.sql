@@ -0,0 +1,25 @@
+-- Push notifications: the app registers each device's FCM or APNs token
+-- for the signed-in user. A token belongs to one app install, so it is
+-- unique and moves to whoever signs in on the device. Users may turn push
+-- or SMS off per kind of notification; without a row a channel is on.
+
+CREATE TABLE IF NOT EXISTS customer_schema.push_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    provider VARCHAR(10) NOT NULL CHECK (provider IN ('fcm', 'apns')),
+    token TEXT NOT NULL UNIQUE,
+    device_name VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_tokens_user ON customer_schema.push_tokens(user_id);
+
+CREATE TABLE IF NOT EXISTS customer_schema.notification_preferences (
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('push', 'sms')),
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, event_type, channel)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS customer_schema.idx_notifications_user_created;
+ALTER TABLE customer_schema.notifications DROP COLUMN IF EXISTS read_at;
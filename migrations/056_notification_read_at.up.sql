@@ -0,0 +1,9 @@
+-- Notification center: remember when each notification was read, and serve
+-- a user's list and unread count from an index rather than scanning all of
+-- their notifications.
+
+ALTER TABLE customer_schema.notifications ADD COLUMN IF NOT EXISTS read_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created
+    ON customer_schema.notifications(user_id, created_at DESC)
+    WHERE is_archived = FALSE;
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS customer_schema.credit_interest_accruals;
+DROP TABLE IF EXISTS customer_schema.credit_facilities;
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_transaction_type_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_transaction_type_check CHECK (transaction_type IN (
+    'payment', 'transfer', 'withdrawal', 'deposit',
+    'refund', 'reversal', 'settlement', 'adjustment', 'escheatment'
+));
+ALTER TABLE customer_schema.wallets DROP CONSTRAINT IF EXISTS wallets_available_balance_check;
+ALTER TABLE customer_schema.wallets DROP CONSTRAINT IF EXISTS wallets_ledger_balance_check;
+ALTER TABLE customer_schema.wallets ADD CONSTRAINT wallets_available_balance_check CHECK (available_balance >= 0);
+ALTER TABLE customer_schema.wallets ADD CONSTRAINT wallets_ledger_balance_check CHECK (ledger_balance >= 0);
+ALTER TABLE customer_schema.wallets DROP COLUMN IF EXISTS overdraft_limit;
+DELETE FROM customer_schema.ledger_accounts WHERE code IN ('1030', '4030');
@@ -0,0 +1,67 @@
+-- Overdraft facilities: a verified, low-risk customer may be given a credit
+-- limit on one wallet, letting payments take it below zero down to minus
+-- the limit. Interest accrues daily on the overdrawn balance into interest
+-- receivable and is collected from the wallet once credits bring it back
+-- above zero. Customers who stay overdrawn for too long become past due,
+-- then delinquent.
+
+INSERT INTO customer_schema.ledger_accounts (code, name, account_type, description) VALUES
+    ('1030', 'credit_interest_receivable', 'asset', 'Interest accrued on overdrawn wallets and not yet collected'),
+    ('4030', 'credit_interest_income', 'income', 'Interest charged on overdrawn wallets')
+ON CONFLICT (code) DO NOTHING;
+
+-- A wallet may go as far below zero as its overdraft limit, which stays
+-- zero for wallets without a facility.
+ALTER TABLE customer_schema.wallets ADD COLUMN IF NOT EXISTS overdraft_limit DECIMAL(20,2) NOT NULL DEFAULT 0
+    CHECK (overdraft_limit >= 0);
+ALTER TABLE customer_schema.wallets DROP CONSTRAINT IF EXISTS wallets_available_balance_check;
+ALTER TABLE customer_schema.wallets DROP CONSTRAINT IF EXISTS wallets_ledger_balance_check;
+ALTER TABLE customer_schema.wallets ADD CONSTRAINT wallets_available_balance_check
+    CHECK (available_balance >= -overdraft_limit);
+ALTER TABLE customer_schema.wallets ADD CONSTRAINT wallets_ledger_balance_check
+    CHECK (ledger_balance >= -overdraft_limit);
+
+ALTER TABLE customer_schema.transactions DROP CONSTRAINT IF EXISTS transactions_transaction_type_check;
+ALTER TABLE customer_schema.transactions ADD CONSTRAINT transactions_transaction_type_check CHECK (transaction_type IN (
+    'payment', 'transfer', 'withdrawal', 'deposit',
+    'refund', 'reversal', 'settlement', 'adjustment', 'escheatment', 'credit_interest'
+));
+
+CREATE TABLE IF NOT EXISTS customer_schema.credit_facilities (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id),
+    currency VARCHAR(3) NOT NULL,
+    credit_limit DECIMAL(20,2) NOT NULL CHECK (credit_limit > 0),
+    annual_rate DECIMAL(8,6) NOT NULL CHECK (annual_rate >= 0 AND annual_rate <= 1),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('active', 'suspended', 'closed')),
+    delinquency VARCHAR(20) NOT NULL DEFAULT 'current' CHECK (delinquency IN ('current', 'past_due', 'delinquent')),
+    overdrawn_since TIMESTAMPTZ,
+    accrued_interest DECIMAL(20,2) NOT NULL DEFAULT 0,
+    interest_collected DECIMAL(20,2) NOT NULL DEFAULT 0,
+    accrued_through DATE NOT NULL,
+    opened_by UUID,
+    opened_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- One open facility per wallet; a closed one stays as history
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_facilities_open_wallet
+    ON customer_schema.credit_facilities(wallet_id) WHERE status <> 'closed';
+CREATE INDEX IF NOT EXISTS idx_credit_facilities_user ON customer_schema.credit_facilities(user_id);
+
+-- Each accrual covers the days from period_start up to period_end, so a
+-- rerun for the same days is refused.
+CREATE TABLE IF NOT EXISTS customer_schema.credit_interest_accruals (
+    id UUID PRIMARY KEY,
+    facility_id UUID NOT NULL REFERENCES customer_schema.credit_facilities(id),
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    overdrawn DECIMAL(20,2) NOT NULL,
+    annual_rate DECIMAL(8,6) NOT NULL,
+    amount DECIMAL(20,2) NOT NULL,
+    journal_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (facility_id, period_end)
+);
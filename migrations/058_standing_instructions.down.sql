@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS customer_schema.idx_transactions_receiver_wallet_completed;
+DROP TABLE IF EXISTS customer_schema.standing_instruction_executions;
+DROP TABLE IF EXISTS customer_schema.standing_instructions;
+-- Give pocket money back to spending before the pockets go
+UPDATE customer_schema.wallets w
+SET available_balance = w.available_balance + p.total,
+    reserved_balance = w.reserved_balance - p.total,
+    updated_at = NOW()
+FROM (
+    SELECT wallet_id, SUM(balance) AS total
+    FROM customer_schema.savings_pockets
+    GROUP BY wallet_id
+) p
+WHERE w.id = p.wallet_id AND p.total > 0;
+DROP TABLE IF EXISTS customer_schema.savings_pockets;
@@ -0,0 +1,67 @@
+-- Standing instructions on incoming funds: a customer may have money that
+-- arrives in a wallet converted to another of their currencies, or the
+-- balance above a threshold swept into a savings pocket. A pocket is a
+-- named part of a wallet's balance set aside from spending: its money sits
+-- in the wallet's reserved balance, so the ledger balance is unchanged.
+
+CREATE TABLE IF NOT EXISTS customer_schema.savings_pockets (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id),
+    currency VARCHAR(3) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    balance DECIMAL(20,2) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_savings_pockets_wallet_name
+    ON customer_schema.savings_pockets(wallet_id, LOWER(name));
+CREATE INDEX IF NOT EXISTS idx_savings_pockets_user ON customer_schema.savings_pockets(user_id);
+
+CREATE TABLE IF NOT EXISTS customer_schema.standing_instructions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id),
+    currency VARCHAR(3) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('auto_convert', 'auto_sweep')),
+    target_currency VARCHAR(3),
+    pocket_id UUID REFERENCES customer_schema.savings_pockets(id),
+    threshold DECIMAL(20,2) NOT NULL DEFAULT 0 CHECK (threshold >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Credits that settled before this are left alone, so turning an
+    -- instruction on does not act on old money
+    active_since TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    CHECK ((kind = 'auto_convert' AND target_currency IS NOT NULL AND pocket_id IS NULL)
+        OR (kind = 'auto_sweep' AND pocket_id IS NOT NULL AND target_currency IS NULL))
+);
+
+-- One active instruction per wallet, so a credit is never claimed twice
+CREATE UNIQUE INDEX IF NOT EXISTS idx_standing_instructions_active_wallet
+    ON customer_schema.standing_instructions(wallet_id) WHERE active;
+CREATE INDEX IF NOT EXISTS idx_standing_instructions_user ON customer_schema.standing_instructions(user_id);
+
+-- Each credit is acted on once per instruction: the worker claims it here
+-- before executing.
+CREATE TABLE IF NOT EXISTS customer_schema.standing_instruction_executions (
+    id UUID PRIMARY KEY,
+    instruction_id UUID NOT NULL REFERENCES customer_schema.standing_instructions(id) ON DELETE CASCADE,
+    trigger_transaction_id UUID NOT NULL REFERENCES customer_schema.transactions(id),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'executed', 'skipped', 'failed')),
+    amount DECIMAL(20,2) NOT NULL DEFAULT 0,
+    transaction_id UUID REFERENCES customer_schema.transactions(id),
+    reason TEXT NOT NULL DEFAULT '',
+    claimed_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (instruction_id, trigger_transaction_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_standing_instruction_executions_instruction
+    ON customer_schema.standing_instruction_executions(instruction_id, created_at DESC);
+
+-- The worker looks up settled credits by the wallet they came into
+CREATE INDEX IF NOT EXISTS idx_transactions_receiver_wallet_completed
+    ON customer_schema.transactions(receiver_wallet_id, completed_at)
+    WHERE status = 'completed';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_schema.payment_batch_items;
+DROP TABLE IF EXISTS customer_schema.payment_batches;
@@ -0,0 +1,44 @@
+-- Payment batches: a customer uploads a CSV or JSON file of payments, such
+-- as a payroll. Every item is validated on upload and kept with its errors;
+-- the valid ones are paid in the background, each with its own outcome, so
+-- one failed payment does not hold up the rest.
+
+CREATE TABLE IF NOT EXISTS customer_schema.payment_batches (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'json')),
+    -- The customer's own name for the batch, unique among their batches so
+    -- the same file is not paid twice by mistake
+    reference VARCHAR(64) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('queued', 'processing', 'completed')),
+    total_items INT NOT NULL DEFAULT 0,
+    invalid_items INT NOT NULL DEFAULT 0,
+    processed_items INT NOT NULL DEFAULT 0,
+    succeeded_items INT NOT NULL DEFAULT 0,
+    failed_items INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_batches_user ON customer_schema.payment_batches(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payment_batches_status ON customer_schema.payment_batches(status, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_batches_reference
+    ON customer_schema.payment_batches(user_id, reference) WHERE reference <> '';
+
+CREATE TABLE IF NOT EXISTS customer_schema.payment_batch_items (
+    batch_id UUID NOT NULL REFERENCES customer_schema.payment_batches(id) ON DELETE CASCADE,
+    line INT NOT NULL,
+    -- The payment as written in the file, so invalid items are reported as
+    -- they were sent
+    record JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('invalid', 'pending', 'succeeded', 'failed')),
+    errors JSONB,
+    transaction_id UUID REFERENCES customer_schema.transactions(id),
+    processed_at TIMESTAMPTZ,
+    PRIMARY KEY (batch_id, line)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_batch_items_status ON customer_schema.payment_batch_items(batch_id, status, line);
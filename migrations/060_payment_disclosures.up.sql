@@ -0,0 +1,38 @@
+-- Pre-payment disclosures: before a cross-border payment the sender is shown
+-- its total cost, the exchange rate and its margin, what the recipient gets
+-- and when. Each disclosure is kept exactly as issued and, once paid under,
+-- points to the payment, so the figures a customer agreed to can be audited.
+
+CREATE TABLE IF NOT EXISTS customer_schema.payment_disclosures (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    sender_wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id),
+    receiver_wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id),
+    recipient_name VARCHAR(255) NOT NULL DEFAULT '',
+    -- Set when the payment is made, before its transaction row is written,
+    -- hence no foreign key
+    transaction_id UUID,
+    transfer_amount DECIMAL(20,2) NOT NULL,
+    transfer_currency VARCHAR(3) NOT NULL,
+    transfer_fees DECIMAL(20,2) NOT NULL,
+    transfer_taxes DECIMAL(20,2) NOT NULL DEFAULT 0,
+    total_cost DECIMAL(20,2) NOT NULL,
+    exchange_rate DECIMAL(12,6) NOT NULL,
+    mid_market_rate DECIMAL(12,6) NOT NULL,
+    fx_margin_percent DECIMAL(10,4) NOT NULL DEFAULT 0,
+    fx_margin_amount DECIMAL(20,2) NOT NULL DEFAULT 0,
+    receive_amount DECIMAL(20,2) NOT NULL,
+    receive_currency VARCHAR(3) NOT NULL,
+    other_fees DECIMAL(20,2) NOT NULL DEFAULT 0,
+    total_to_recipient DECIMAL(20,2) NOT NULL,
+    available_by TIMESTAMPTZ NOT NULL,
+    subject_to_review BOOLEAN NOT NULL DEFAULT FALSE,
+    rights_notice TEXT NOT NULL,
+    issued_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_disclosures_user ON customer_schema.payment_disclosures(user_id, issued_at DESC);
+-- A disclosure covers one payment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_disclosures_transaction
+    ON customer_schema.payment_disclosures(transaction_id) WHERE transaction_id IS NOT NULL;
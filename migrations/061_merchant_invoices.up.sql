@@ -0,0 +1,31 @@
+-- Merchant invoices: merchants request money with a payable invoice. Once sent it
+-- is paid through a shareable link, by anyone with the link or only by the
+-- payer it was addressed to, until it expires.
+
+CREATE TABLE IF NOT EXISTS customer_schema.merchant_invoices (
+    id UUID PRIMARY KEY,
+    merchant_id UUID NOT NULL REFERENCES customer_schema.users(id),
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id),
+    payer_id UUID REFERENCES customer_schema.users(id),
+    -- The merchant's own invoice number
+    reference VARCHAR(64) NOT NULL DEFAULT '',
+    amount DECIMAL(20,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    memo VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('draft', 'sent', 'paid', 'expired', 'cancelled')),
+    -- Identifies the invoice in its payment link
+    token VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    transaction_id UUID REFERENCES customer_schema.transactions(id),
+    paid_by UUID REFERENCES customer_schema.users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    paid_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_invoices_merchant ON customer_schema.merchant_invoices(merchant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_merchant_invoices_expiry ON customer_schema.merchant_invoices(expires_at) WHERE status = 'sent';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_merchant_invoices_reference
+    ON customer_schema.merchant_invoices(merchant_id, reference) WHERE reference <> '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_schema.complaint_returns;
+DROP TABLE IF EXISTS customer_schema.complaints;
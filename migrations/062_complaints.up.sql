@@ -0,0 +1,47 @@
+-- Complaints register: complaints from customers, and from people without an
+-- account, with their acknowledgement and resolution deadlines, how they
+-- were resolved and why they arose. Quarterly returns to the Reserve Bank of
+-- Malawi are kept as generated.
+
+CREATE TABLE IF NOT EXISTS customer_schema.complaints (
+    id UUID PRIMARY KEY,
+    reference VARCHAR(32) NOT NULL UNIQUE,
+    user_id UUID REFERENCES customer_schema.users(id),
+    transaction_id UUID REFERENCES customer_schema.transactions(id),
+    complainant_name VARCHAR(255) NOT NULL DEFAULT '',
+    complainant_contact VARCHAR(255) NOT NULL DEFAULT '',
+    channel VARCHAR(20) NOT NULL,
+    category VARCHAR(40) NOT NULL,
+    language VARCHAR(8) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    amount DECIMAL(20,2),
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('received', 'acknowledged', 'resolved')),
+    received_at TIMESTAMPTZ NOT NULL,
+    acknowledge_by TIMESTAMPTZ NOT NULL,
+    acknowledged_at TIMESTAMPTZ,
+    resolve_by TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    outcome VARCHAR(20),
+    root_cause VARCHAR(40),
+    resolution TEXT NOT NULL DEFAULT '',
+    redress_amount DECIMAL(20,2),
+    assigned_to UUID REFERENCES customer_schema.users(id),
+    created_by UUID REFERENCES customer_schema.users(id),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (user_id IS NOT NULL OR complainant_name <> '')
+);
+
+CREATE INDEX IF NOT EXISTS idx_complaints_user ON customer_schema.complaints(user_id, received_at DESC);
+CREATE INDEX IF NOT EXISTS idx_complaints_open ON customer_schema.complaints(resolve_by) WHERE status <> 'resolved';
+CREATE INDEX IF NOT EXISTS idx_complaints_received ON customer_schema.complaints(received_at);
+
+CREATE TABLE IF NOT EXISTS customer_schema.complaint_returns (
+    period VARCHAR(7) PRIMARY KEY,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    complaints INTEGER NOT NULL,
+    content BYTEA NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
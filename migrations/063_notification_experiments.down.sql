@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_schema.notification_deliveries;
+DROP TABLE IF EXISTS customer_schema.notification_experiments;
@@ -0,0 +1,33 @@
+-- Notification A/B tests: an experiment gives users one of several
+-- variants of an event's subject and body, picked from a hash of the
+-- experiment and the user. Each notification sent under it is recorded with
+-- its variant, and the app reports when it was opened and clicked through
+-- from. An event has at most one running experiment.
+
+CREATE TABLE IF NOT EXISTS customer_schema.notification_experiments (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('running', 'stopped')),
+    variants JSONB NOT NULL,
+    created_by UUID REFERENCES customer_schema.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    stopped_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_experiments_running
+    ON customer_schema.notification_experiments(event_type)
+    WHERE status = 'running';
+
+CREATE TABLE IF NOT EXISTS customer_schema.notification_deliveries (
+    notification_id UUID PRIMARY KEY,
+    experiment_id UUID NOT NULL REFERENCES customer_schema.notification_experiments(id) ON DELETE CASCADE,
+    variant VARCHAR(32) NOT NULL,
+    user_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    delivered_at TIMESTAMPTZ NOT NULL,
+    opened_at TIMESTAMPTZ,
+    clicked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_experiment
+    ON customer_schema.notification_deliveries(experiment_id, variant);
@@ -0,0 +1,29 @@
+-- Fee schedules price payments by corridor, channel, user type and KYC
+-- level. Null criteria match anything; the schedule setting the most
+-- criteria wins, then the latest to take effect. Flat fees and bounds are
+-- in the source currency. Payments no schedule matches are charged the
+-- sender's plan or segment rate.
+
+CREATE TABLE IF NOT EXISTS admin_schema.fee_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    source_currency VARCHAR(3),
+    destination_currency VARCHAR(3),
+    channel VARCHAR(32),
+    user_type VARCHAR(20),
+    kyc_level INTEGER CHECK (kyc_level IS NULL OR kyc_level BETWEEN 0 AND 3),
+    flat_fee NUMERIC(20, 8) NOT NULL DEFAULT 0 CHECK (flat_fee >= 0),
+    percentage NUMERIC(10, 6) NOT NULL DEFAULT 0 CHECK (percentage >= 0 AND percentage < 1),
+    min_fee NUMERIC(20, 8) CHECK (min_fee IS NULL OR min_fee >= 0),
+    max_fee NUMERIC(20, 8) CHECK (max_fee IS NULL OR max_fee >= 0),
+    effective_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    effective_to TIMESTAMPTZ,
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (min_fee IS NULL OR max_fee IS NULL OR min_fee <= max_fee),
+    CHECK (effective_to IS NULL OR effective_to > effective_from),
+    CHECK (source_currency IS NOT NULL OR (flat_fee = 0 AND min_fee IS NULL AND max_fee IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_fee_schedules_effective_to ON admin_schema.fee_schedules(effective_to);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS admin_schema.idx_webhook_deliveries_event;
+DROP TABLE IF EXISTS admin_schema.statement_deliveries;
+DROP TABLE IF EXISTS admin_schema.statement_schedules;
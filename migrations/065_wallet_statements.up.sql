@@ -0,0 +1,56 @@
+-- Corporate wallet statements. A schedule sends one wallet's statement for
+-- each UTC day, as camt.053 or CSV, to the owner's webhook endpoints or the
+-- outbound folder of their file channel. Each statement sent is a delivery
+-- that keeps the file, so retries send the same bytes; regenerating a day
+-- adds a delivery pointing at the one it replaces.
+
+CREATE TABLE IF NOT EXISTS admin_schema.statement_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('camt053', 'csv')),
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('webhook', 'sftp')),
+    -- The last UTC day a statement was queued for.
+    last_period DATE,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (wallet_id, format, channel)
+);
+
+CREATE INDEX IF NOT EXISTS idx_statement_schedules_owner ON admin_schema.statement_schedules(owner_id);
+
+CREATE TABLE IF NOT EXISTS admin_schema.statement_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    schedule_id UUID REFERENCES admin_schema.statement_schedules(id) ON DELETE SET NULL,
+    owner_id UUID NOT NULL REFERENCES customer_schema.users(id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id) ON DELETE CASCADE,
+    period DATE NOT NULL,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('camt053', 'csv')),
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('webhook', 'sftp')),
+    regenerated_from UUID REFERENCES admin_schema.statement_deliveries(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    file_size BIGINT NOT NULL DEFAULT 0,
+    sha256 VARCHAR(64) NOT NULL DEFAULT '',
+    entry_count INTEGER NOT NULL DEFAULT 0,
+    content BYTEA,
+    requested_by UUID,
+    sent_at TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_statement_deliveries_owner ON admin_schema.statement_deliveries(owner_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_statement_deliveries_schedule ON admin_schema.statement_deliveries(schedule_id, period);
+CREATE INDEX IF NOT EXISTS idx_statement_deliveries_due ON admin_schema.statement_deliveries(next_attempt_at)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_statement_deliveries_sent ON admin_schema.statement_deliveries(updated_at)
+    WHERE status = 'sent';
+
+-- Statements delivered by webhook are tracked through the deliveries of
+-- their event.
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON admin_schema.webhook_deliveries(event_id);
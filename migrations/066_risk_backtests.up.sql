@@ -0,0 +1,26 @@
+-- Risk backtests replay the payments of a past window through candidate
+-- risk rules and through the rules live when they were requested, and
+-- record how the two compare. They only read production data.
+
+CREATE TABLE IF NOT EXISTS admin_schema.risk_backtests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    window_from TIMESTAMPTZ NOT NULL,
+    window_to TIMESTAMPTZ NOT NULL,
+    baseline JSONB NOT NULL,
+    candidate JSONB NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    processed INTEGER NOT NULL DEFAULT 0,
+    results JSONB,
+    error TEXT,
+    requested_by UUID NOT NULL,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (window_to > window_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_backtests_created_at ON admin_schema.risk_backtests(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_risk_backtests_pending ON admin_schema.risk_backtests(created_at)
+    WHERE status IN ('queued', 'running');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS admin_schema.restricted_jurisdiction_changes;
+DROP TABLE IF EXISTS admin_schema.restricted_jurisdictions;
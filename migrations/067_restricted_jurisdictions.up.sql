@@ -0,0 +1,41 @@
+-- Restricted jurisdictions: countries money may not come from (inbound), go
+-- to (outbound) or both, between effective dates. Every change is kept in
+-- the append-only change log for regulator review, including removals, so
+-- the log has no foreign key to the restrictions.
+
+CREATE TABLE IF NOT EXISTS admin_schema.restricted_jurisdictions (
+    id UUID PRIMARY KEY,
+    country_code CHAR(2) NOT NULL,
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('inbound', 'outbound', 'both')),
+    reason TEXT NOT NULL,
+    authority TEXT NOT NULL DEFAULT '',
+    effective_from TIMESTAMPTZ NOT NULL,
+    effective_until TIMESTAMPTZ,
+    created_by UUID NOT NULL,
+    updated_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (effective_until IS NULL OR effective_until > effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_restricted_jurisdictions_country
+    ON admin_schema.restricted_jurisdictions(country_code, effective_from);
+CREATE INDEX IF NOT EXISTS idx_restricted_jurisdictions_until
+    ON admin_schema.restricted_jurisdictions(effective_until);
+
+CREATE TABLE IF NOT EXISTS admin_schema.restricted_jurisdiction_changes (
+    id UUID PRIMARY KEY,
+    jurisdiction_id UUID NOT NULL,
+    country_code CHAR(2) NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('created', 'updated', 'deleted')),
+    before JSONB,
+    after JSONB,
+    note TEXT NOT NULL,
+    changed_by UUID NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_restricted_jurisdiction_changes_at
+    ON admin_schema.restricted_jurisdiction_changes(changed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_restricted_jurisdiction_changes_country
+    ON admin_schema.restricted_jurisdiction_changes(country_code, changed_at DESC);
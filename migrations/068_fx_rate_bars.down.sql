@@ -0,0 +1 @@
+DROP TABLE IF EXISTS customer_schema.fx_rate_bars;
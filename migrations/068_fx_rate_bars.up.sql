@@ -0,0 +1,22 @@
+-- Historical FX rates: the mid-market rate of each snapshotted pair,
+-- downsampled into minute, hour and day OHLC bars. Each sample updates the
+-- bar of every resolution it falls in, so no rollup job is needed; minute
+-- and hour bars are pruned after their retention, day bars are kept.
+
+CREATE TABLE IF NOT EXISTS customer_schema.fx_rate_bars (
+    base_currency VARCHAR(3) NOT NULL,
+    target_currency VARCHAR(3) NOT NULL,
+    resolution VARCHAR(6) NOT NULL CHECK (resolution IN ('minute', 'hour', 'day')),
+    bucket_start TIMESTAMPTZ NOT NULL,
+    open DECIMAL(20, 8) NOT NULL,
+    high DECIMAL(20, 8) NOT NULL,
+    low DECIMAL(20, 8) NOT NULL,
+    close DECIMAL(20, 8) NOT NULL,
+    samples INTEGER NOT NULL DEFAULT 1,
+    first_at TIMESTAMPTZ NOT NULL,
+    last_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (base_currency, target_currency, resolution, bucket_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fx_rate_bars_prune
+    ON customer_schema.fx_rate_bars(resolution, bucket_start);
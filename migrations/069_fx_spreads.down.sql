@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS customer_schema.idx_tx_fx_revenue;
+DROP TABLE IF EXISTS admin_schema.fx_spreads;
@@ -0,0 +1,25 @@
+-- FX spreads are the margin taken on a currency pair, replacing the
+-- dynamic spread for it: the base to target rate is sold at the mid
+-- rate less the margin and bought at the mid rate plus it. A
+-- spread is a percentage of the mid rate or a number of pips.
+
+CREATE TABLE IF NOT EXISTS admin_schema.fx_spreads (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    base_currency VARCHAR(3) NOT NULL,
+    target_currency VARCHAR(3) NOT NULL,
+    unit VARCHAR(10) NOT NULL CHECK (unit IN ('percent', 'pips')),
+    value NUMERIC(20, 8) NOT NULL CHECK (value >= 0),
+    pip_size NUMERIC(20, 10) CHECK (pip_size IS NULL OR pip_size > 0),
+    note TEXT NOT NULL DEFAULT '',
+    updated_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (base_currency, target_currency),
+    CHECK (base_currency <> target_currency),
+    CHECK (unit <> 'percent' OR (value <= 10 AND pip_size IS NULL))
+);
+
+-- The FX revenue report reads completed cross-currency payments by day.
+CREATE INDEX IF NOT EXISTS idx_tx_fx_revenue
+    ON customer_schema.transactions (created_at, currency, converted_currency)
+    WHERE status = 'completed' AND currency <> converted_currency;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS customer_schema.idx_ledger_entries_wallet_created;
+DROP TABLE IF EXISTS customer_schema.wallet_balance_snapshots;
@@ -0,0 +1,28 @@
+-- Periodic copies of every wallet's balances, from which a balance at any
+-- past moment is rebuilt by replaying the ledger entries after the latest
+-- copy before it. Each copy records the last ledger entry it includes, so
+-- the replay neither misses nor repeats an entry written while it was
+-- taken. period_start makes taking a period's copies idempotent across
+-- instances.
+
+CREATE TABLE IF NOT EXISTS customer_schema.wallet_balance_snapshots (
+    wallet_id UUID NOT NULL REFERENCES customer_schema.wallets(id) ON DELETE CASCADE,
+    period_start TIMESTAMPTZ NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    available_balance DECIMAL(20, 2) NOT NULL,
+    ledger_balance DECIMAL(20, 2) NOT NULL,
+    reserved_balance DECIMAL(20, 2) NOT NULL,
+    last_entry_id UUID,
+    last_entry_at TIMESTAMPTZ,
+    PRIMARY KEY (wallet_id, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_balance_snapshots_taken
+    ON customer_schema.wallet_balance_snapshots(wallet_id, taken_at);
+CREATE INDEX IF NOT EXISTS idx_wallet_balance_snapshots_prune
+    ON customer_schema.wallet_balance_snapshots(period_start);
+
+-- The replay reads one wallet's entries in a time range.
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_wallet_created
+    ON customer_schema.ledger_entries(wallet_id, created_at);
@@ -0,0 +1,15 @@
+-- Idempotency keys of payment initiations made while Redis was down. The
+-- idempotency middleware keeps them here instead, and checks here after a
+-- Redis miss, so a retry is answered with the first response during the
+-- outage and after it. Expired keys are cleared as new ones are taken.
+
+CREATE TABLE IF NOT EXISTS customer_schema.idempotency_keys (
+    key VARCHAR(300) PRIMARY KEY,
+    response BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires
+    ON customer_schema.idempotency_keys(expires_at);
@@ -0,0 +1,13 @@
+-- Revoked tokens and devices, kept alongside the Redis blacklist so token
+-- checks still refuse them while Redis is down and after it is back. Keys
+-- are SHA-256 hashes of what was revoked, never the token itself. Expired
+-- rows are cleared as new ones are added.
+
+CREATE TABLE IF NOT EXISTS customer_schema.revoked_tokens (
+    key_hash CHAR(64) PRIMARY KEY,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires
+    ON customer_schema.revoked_tokens(expires_at);
@@ -0,0 +1 @@
+DELETE FROM admin_schema.role_permissions WHERE permission = 'users:unmask';
@@ -0,0 +1,7 @@
+-- Unmasking a record needs users:unmask instead of users:read, so support
+-- no longer sees personal data in full. Compliance officers keep it; admins
+-- hold every permission.
+
+INSERT INTO admin_schema.role_permissions (role, permission) VALUES
+    ('compliance_officer', 'users:unmask')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,268 @@
+// Package bootstrap is the shared startup and shutdown path of the services
+// and workers under cmd/. A main builds an App, connects the dependencies it
+// needs, wires its repositories, services and routes (see internal/server),
+// and hands the router to Serve (or calls Wait for workers without an HTTP
+// listener):
+//
+//	app := bootstrap.New("wallet")
+//	app.ConnectDatabase()
+//	app.ConnectRedis()
+//	h, err := server.Wallet(app)
+//	...
+//	app.Serve(h)
+//
+// Everything registered along the way (pools, clients, workers) is closed in
+// reverse order on SIGINT/SIGTERM, and also when startup fails after a
+// dependency was opened.
+package bootstrap
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"kyd/internal/middleware"
+	"kyd/internal/repository/postgres"
+	"kyd/pkg/clock"
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+	"kyd/pkg/metrics"
+	"kyd/pkg/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+)
+
+// Worker is a background job with the Start/Stop lifecycle used throughout
+// the services.
+type Worker interface {
+	Start()
+	Stop()
+}
+
+// App holds the configuration and shared dependencies of one service.
+type App struct {
+	Name   string
+	Config *config.Config
+	Logger logger.Logger
+	// DBConfig is the service's pool tuning, see config.DatabaseConfig.ForService.
+	DBConfig config.DatabaseConfig
+	DB       *sqlx.DB
+	Redis    *redis.Client
+	// RedisGuard takes the service off Redis while it is down; see
+	// config.RedisConfig.
+	RedisGuard *middleware.RedisGuard
+
+	grpc     *grpc.Server
+	grpcOnce sync.Once
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closers []func()
+	closed  bool
+
+	// exit ends the process after a fatal startup error; replaced in tests.
+	exit func(code int)
+}
+
+// New loads and validates the configuration and creates the service logger.
+// name is the short service name ("payment", "wallet") used in logs, pool
+// metrics and per-service environment overrides.
+func New(name string) *App {
+	cfg := config.Load()
+	log := logger.New(name + "-service")
+	a := newApp(name, cfg, log)
+	if err := cfg.ValidateCore(); err != nil {
+		a.Fatal("Invalid configuration", err)
+	}
+	if err := clock.Init(cfg.Time.BusinessZone); err != nil {
+		a.Fatal("Invalid BUSINESS_TIMEZONE", err)
+	}
+	a.setupTracing()
+	return a
+}
+
+// setupTracing starts exporting spans when tracing is enabled and flushes
+// them last on shutdown, after the spans of closing workers have ended.
+func (a *App) setupTracing() {
+	flush, err := tracing.Setup(a.ctx, a.Config.Tracing, a.Name)
+	if err != nil {
+		a.Fatal("Failed to set up tracing", err)
+	}
+	a.OnShutdown(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := flush(ctx); err != nil {
+			a.Logger.Warn("Failed to flush traces", map[string]interface{}{"error": err.Error()})
+		}
+	})
+}
+
+func newApp(name string, cfg *config.Config, log logger.Logger) *App {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &App{
+		Name:     name,
+		Config:   cfg,
+		Logger:   log,
+		DBConfig: cfg.Database.ForService(name),
+		ctx:      ctx,
+		cancel:   cancel,
+		exit:     exitProcess,
+	}
+}
+
+// Context is cancelled when shutdown begins. Pass it to goroutines that do
+// not follow the Worker lifecycle.
+func (a *App) Context() context.Context {
+	return a.ctx
+}
+
+// ConnectDatabase opens the service's tuned, instrumented pool, starts its
+// saturation monitor and checks the plans of the critical queries.
+func (a *App) ConnectDatabase() *sqlx.DB {
+	postgres.ConfigureQueryInstrumentation(a.DBConfig.SlowQuery, a.DBConfig.PrepareStatements, a.Logger)
+	db, err := postgres.Connect(a.DBConfig)
+	if err != nil {
+		a.Fatal("Failed to connect to database", err)
+	}
+	a.OnShutdown(func() { db.Close() })
+	a.DB = db
+
+	ctx, cancel := context.WithTimeout(a.ctx, 10*time.Second)
+	err = db.PingContext(ctx)
+	cancel()
+	if err != nil {
+		a.Fatal("Database ping failed", err)
+	}
+	a.Start(postgres.NewPoolMonitor(a.Name, db, time.Minute, a.Logger))
+	metrics.RegisterDBPool(a.Name, db.DB)
+
+	ctx, cancel = context.WithTimeout(a.ctx, 15*time.Second)
+	if _, err := postgres.CheckQueryPlans(ctx, db, a.Logger); err != nil {
+		a.Logger.Warn("Query plan check failed", map[string]interface{}{"error": err.Error()})
+	}
+	cancel()
+
+	a.Logger.Info("Database connected", map[string]interface{}{"max_open_conns": a.DBConfig.MaxOpenConns})
+	return db
+}
+
+// ConnectRedis opens and pings the traced, timed and guarded Redis client.
+func (a *App) ConnectRedis() *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     a.Config.Redis.URL,
+		Password: a.Config.Redis.Password,
+		DB:       a.Config.Redis.DB,
+	})
+	a.RedisGuard = middleware.NewRedisGuard(a.Name, a.Config.Redis.RetryAfter, a.Logger)
+	client.AddHook(tracing.RedisHook{})
+	client.AddHook(metrics.RedisHook{})
+	client.AddHook(a.RedisGuard)
+	a.OnShutdown(func() { client.Close() })
+	a.Redis = client
+
+	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		a.Fatal("Failed to connect to Redis", err)
+	}
+	a.Logger.Info("Redis connected", nil)
+	return client
+}
+
+// RateLimiter returns a limiter named name on the service's Redis that
+// fails as REDIS_RATE_LIMIT_FAILURE says while Redis is down.
+func (a *App) RateLimiter(name string, limit int, window time.Duration) *middleware.RateLimiter {
+	return middleware.NewRateLimiter(a.Redis, limit, window).WithName(name).
+		WithFailureMode(middleware.FailureMode(a.Config.Redis.RateLimitFailure))
+}
+
+// TokenBlacklist returns the revoked tokens on the service's Redis, also
+// kept in the database, checked as REDIS_TOKEN_CHECK_FAILURE says while
+// Redis is down. app must be connected to the database.
+func (a *App) TokenBlacklist() *middleware.RedisTokenBlacklist {
+	return middleware.NewRedisTokenBlacklist(a.Redis).
+		WithFailureMode(middleware.FailureMode(a.Config.Redis.TokenCheckFailure)).
+		WithFallback(postgres.NewTokenRevocationRepository(a.DB))
+}
+
+// Start starts w now and stops it on shutdown, before the connections it
+// uses are closed.
+func (a *App) Start(w Worker) {
+	w.Start()
+	a.OnShutdown(w.Stop)
+}
+
+// OnShutdown registers fn to run on shutdown. Functions run in reverse
+// registration order, so dependencies outlive their users.
+func (a *App) OnShutdown(fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closers = append(a.closers, fn)
+}
+
+// Fatal logs err, releases everything opened so far and exits. Use it
+// instead of Logger.Fatal once dependencies are open, since the latter skips
+// deferred cleanup.
+func (a *App) Fatal(message string, err error) {
+	a.Logger.Error(message, map[string]interface{}{"error": err.Error()})
+	a.shutdown()
+	a.exit(1)
+}
+
+// Shutdown runs the shutdown hooks without waiting for a signal, for apps
+// embedded in another process such as the integration tests.
+func (a *App) Shutdown() {
+	a.shutdown()
+}
+
+// shutdown cancels the app context and runs the registered closers once.
+func (a *App) shutdown() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	closers := a.closers
+	a.closers = nil
+	a.mu.Unlock()
+
+	a.cancel()
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i]()
+	}
+}
+
+// LoadDotEnv copies KEY=VALUE lines from .env in the working directory, or
+// from the repository root when run from cmd/<service>, into the process
+// environment without overriding variables that are already set. Call it
+// before New.
+func LoadDotEnv() {
+	content, err := os.ReadFile(".env")
+	if err != nil {
+		content, err = os.ReadFile("../../.env")
+		if err != nil {
+			return
+		}
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if os.Getenv(k) == "" {
+			os.Setenv(k, v)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package bootstrap
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"kyd/pkg/config"
+	"kyd/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingWorker struct {
+	name string
+	log  *[]string
+}
+
+func (w recordingWorker) Start() { *w.log = append(*w.log, "start "+w.name) }
+func (w recordingWorker) Stop()  { *w.log = append(*w.log, "stop "+w.name) }
+
+func testApp() *App {
+	cfg := &config.Config{}
+	cfg.Database.QueryTimeout = time.Second
+	return newApp("test", cfg, logger.NewNop())
+}
+
+func TestShutdown_ReverseOrderOnce(t *testing.T) {
+	a := testApp()
+	var log []string
+	a.OnShutdown(func() { log = append(log, "close db") })
+	a.Start(recordingWorker{"exports", &log})
+	a.Start(recordingWorker{"alerts", &log})
+
+	a.shutdown()
+	a.shutdown()
+
+	assert.Equal(t, []string{"start exports", "start alerts", "stop alerts", "stop exports", "close db"}, log)
+	assert.Error(t, a.Context().Err())
+}
+
+func TestFatal_ReleasesBeforeExit(t *testing.T) {
+	a := testApp()
+	var closed bool
+	code := -1
+	a.OnShutdown(func() { closed = true })
+	a.exit = func(c int) { code = c }
+
+	a.Fatal("Failed to connect", errors.New("refused"))
+	assert.True(t, closed)
+	assert.Equal(t, 1, code)
+}
+
+func TestNewRouter_StandardRoutes(t *testing.T) {
+	a := testApp()
+	r := a.NewRouter(RouterConfig{})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"service":"test"`)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Requests through the router are counted, /metrics included.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `kyd_http_requests_total{code="200",method="GET",route="/health",service="test"}`)
+}
+
+func TestClientAuthTLS_RejectsEmptyCA(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/ca.pem"
+	require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0o600))
+
+	_, err := clientAuthTLS(config.ServerConfig{CAFile: path})
+	assert.Error(t, err)
+
+	_, err = clientAuthTLS(config.ServerConfig{CAFile: dir + "/missing.pem"})
+	assert.Error(t, err)
+}
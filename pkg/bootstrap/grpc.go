@@ -0,0 +1,129 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"kyd/pkg/config"
+	"kyd/pkg/errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// GRPC returns the app's gRPC server, created on first use. Services
+// register their internal APIs on it (see internal/grpc); Serve then also
+// listens on GRPC_PORT, with mutual TLS when SERVER_USE_TLS is set. In
+// cmd/all every in-process service shares the one server.
+func (a *App) GRPC() *grpc.Server {
+	a.grpcOnce.Do(func() {
+		opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(a.recoverGRPC, a.logGRPC)}
+		if a.Config.Server.UseTLS {
+			tlsConfig, err := grpcServerTLS(a.Config.Server)
+			if err != nil {
+				a.Fatal("Failed to configure gRPC TLS", err)
+				return
+			}
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		a.grpc = grpc.NewServer(opts...)
+	})
+	return a.grpc
+}
+
+// grpcServerTLS is clientAuthTLS with the server's own certificate, which
+// gRPC takes in the config rather than from files at listen time.
+func grpcServerTLS(cfg config.ServerConfig) (*tls.Config, error) {
+	tlsConfig, err := clientAuthTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	keyPair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load server keypair")
+	}
+	tlsConfig.Certificates = []tls.Certificate{keyPair}
+	return tlsConfig, nil
+}
+
+// serveGRPC starts listening for the gRPC server, if one was created, and
+// returns the function that stops it. A listener failure is sent on failed.
+func (a *App) serveGRPC(failed chan<- error) func(ctx context.Context) {
+	srv := a.grpc
+	if srv == nil {
+		return func(context.Context) {}
+	}
+
+	addr := fmt.Sprintf("%s:%s", a.Config.Server.Host, a.Config.GRPC.Port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		failed <- errors.Wrap(err, "failed to listen for gRPC")
+		return func(context.Context) {}
+	}
+	go func() {
+		a.Logger.Info("gRPC server started", map[string]interface{}{
+			"service": a.Name,
+			"address": addr,
+			"tls":     a.Config.Server.UseTLS,
+		})
+		if err := srv.Serve(lis); err != nil {
+			select {
+			case failed <- err:
+			default:
+			}
+		}
+	}()
+
+	// GracefulStop waits for in-flight calls; give up on them when ctx
+	// ends, as the HTTP server does.
+	return func(ctx context.Context) {
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			srv.Stop()
+		}
+	}
+}
+
+// recoverGRPC turns a panicking call into an Internal error, as the
+// Recovery middleware does for HTTP.
+func (a *App) recoverGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			a.Logger.Error("gRPC call panicked", map[string]interface{}{
+				"method": info.FullMethod,
+				"panic":  fmt.Sprint(p),
+				"stack":  string(debug.Stack()),
+			})
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// logGRPC logs calls that failed on the server's side.
+func (a *App) logGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	switch status.Code(err) {
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+		a.Logger.Error("gRPC call failed", map[string]interface{}{
+			"method":   info.FullMethod,
+			"code":     status.Code(err).String(),
+			"error":    err.Error(),
+			"duration": time.Since(start).String(),
+		})
+	}
+	return resp, err
+}
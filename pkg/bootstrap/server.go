@@ -0,0 +1,130 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"kyd/pkg/config"
+	"kyd/pkg/errors"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to finish.
+const shutdownTimeout = 30 * time.Second
+
+func exitProcess(code int) { os.Exit(code) }
+
+// Serve listens on the configured address (with mutual TLS when
+// SERVER_USE_TLS is set), and on GRPC_PORT when gRPC services were
+// registered, until SIGINT or SIGTERM, then drains in-flight requests and
+// runs the shutdown hooks. A listener failure shuts down the same way and
+// exits non-zero.
+func (a *App) Serve(h http.Handler) {
+	a.serve(h, a.Config.Server.UseTLS)
+}
+
+// ServeEdge is Serve for a process that faces clients directly, like the
+// gateway: it listens on plain HTTP behind the TLS-terminating load balancer
+// and asks for no client certificate. SERVER_USE_TLS then only concerns the
+// calls it makes to other services and its gRPC listener.
+func (a *App) ServeEdge(h http.Handler) {
+	a.serve(h, false)
+}
+
+func (a *App) serve(h http.Handler, useTLS bool) {
+	srv := &http.Server{
+		Addr:         fmt.Sprintf("%s:%s", a.Config.Server.Host, a.Config.Server.Port),
+		Handler:      h,
+		ReadTimeout:  a.Config.Server.ReadTimeout,
+		WriteTimeout: a.Config.Server.WriteTimeout,
+		IdleTimeout:  a.Config.Server.IdleTimeout,
+	}
+	if useTLS {
+		tlsConfig, err := clientAuthTLS(a.Config.Server)
+		if err != nil {
+			a.Fatal("Failed to configure TLS", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	// One slot each for the HTTP and gRPC listeners.
+	failed := make(chan error, 2)
+	stopGRPC := a.serveGRPC(failed)
+	go func() {
+		a.Logger.Info("Service started", map[string]interface{}{
+			"service": a.Name,
+			"address": srv.Addr,
+			"tls":     useTLS,
+		})
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS(a.Config.Server.CertFile, a.Config.Server.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+
+	code := 0
+	select {
+	case sig := <-a.signals():
+		a.Logger.Info("Shutting down", map[string]interface{}{"service": a.Name, "signal": sig.String()})
+	case err := <-failed:
+		a.Logger.Error("Server failed", map[string]interface{}{"service": a.Name, "error": err.Error()})
+		code = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		a.Logger.Error("Forced shutdown", map[string]interface{}{"service": a.Name, "error": err.Error()})
+		code = 1
+	}
+	stopGRPC(ctx)
+	a.shutdown()
+	a.Logger.Info("Service stopped", map[string]interface{}{"service": a.Name})
+	if code != 0 {
+		a.exit(code)
+	}
+}
+
+// Wait blocks until SIGINT or SIGTERM and runs the shutdown hooks. It is the
+// Serve of workers that have no HTTP listener.
+func (a *App) Wait() {
+	sig := <-a.signals()
+	a.Logger.Info("Shutting down", map[string]interface{}{"service": a.Name, "signal": sig.String()})
+	a.shutdown()
+	a.Logger.Info("Service stopped", map[string]interface{}{"service": a.Name})
+}
+
+func (a *App) signals() <-chan os.Signal {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	return quit
+}
+
+// clientAuthTLS requires callers (the gateway) to present a certificate
+// signed by the configured CA.
+func clientAuthTLS(cfg config.ServerConfig) (*tls.Config, error) {
+	caCert, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CA file")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("CA file contains no certificates")
+	}
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,104 @@
+// Package clock is the platform's single notion of time. Timestamps are
+// taken, stored and compared in UTC; they are converted to a zone only when
+// presented, either the viewer's (see Stamp) or the business zone that
+// operating hours, weekends and cut-offs are judged in.
+package clock
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	// Embed the zone database so zone names resolve in slim containers
+	// without /usr/share/zoneinfo.
+	_ "time/tzdata"
+)
+
+// UTCName is the zone used when a user has not chosen one.
+const UTCName = "UTC"
+
+var (
+	mu       sync.RWMutex
+	business = time.UTC
+)
+
+// Init makes UTC the process's local zone, so that even a stray time.Now()
+// or time.Local conversion stays in UTC, and sets the business zone.
+func Init(businessZone string) error {
+	loc, err := time.LoadLocation(businessZone)
+	if err != nil {
+		return err
+	}
+	time.Local = time.UTC
+	mu.Lock()
+	business = loc
+	mu.Unlock()
+	return nil
+}
+
+// Now returns the current time in UTC.
+func Now() time.Time {
+	return time.Now().UTC()
+}
+
+// Business returns the zone in which business hours and days are judged.
+func Business() *time.Location {
+	mu.RLock()
+	defer mu.RUnlock()
+	return business
+}
+
+// BusinessNow returns the current time in the business zone.
+func BusinessNow() time.Time {
+	return time.Now().In(Business())
+}
+
+// IsWeekend reports whether t falls on a Saturday or Sunday in the business
+// zone.
+func IsWeekend(t time.Time) bool {
+	d := t.In(Business()).Weekday()
+	return d == time.Saturday || d == time.Sunday
+}
+
+// ValidZone reports whether name is an IANA zone name. "Local" is rejected:
+// it means whatever the server runs in, which is not a user preference.
+func ValidZone(name string) bool {
+	if name == "" || strings.EqualFold(name, "local") {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Location resolves a user's zone preference, falling back to UTC when it is
+// unset or invalid.
+func Location(name string) *time.Location {
+	if !ValidZone(name) {
+		return time.UTC
+	}
+	loc, _ := time.LoadLocation(name)
+	return loc
+}
+
+// displayLayout is how local times are written for people.
+const displayLayout = "2 Jan 2006 15:04 MST"
+
+// Stamp presents an instant both in UTC and in the viewer's zone.
+type Stamp struct {
+	UTC      time.Time `json:"utc"`
+	Local    string    `json:"local"`
+	TimeZone string    `json:"time_zone"`
+	Display  string    `json:"display"`
+}
+
+// In presents t in the zone named by zone, UTC when it is unset or invalid.
+func In(t time.Time, zone string) Stamp {
+	loc := Location(zone)
+	local := t.In(loc)
+	return Stamp{
+		UTC:      t.UTC(),
+		Local:    local.Format(time.RFC3339),
+		TimeZone: loc.String(),
+		Display:  local.Format(displayLayout),
+	}
+}
@@ -0,0 +1,46 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInPresentsUTCAndLocal(t *testing.T) {
+	at := time.Date(2026, 3, 1, 22, 30, 0, 0, time.UTC)
+
+	s := In(at, "Asia/Shanghai")
+	assert.Equal(t, at, s.UTC)
+	assert.Equal(t, "2026-03-02T06:30:00+08:00", s.Local)
+	assert.Equal(t, "Asia/Shanghai", s.TimeZone)
+	assert.Equal(t, "2 Mar 2026 06:30 CST", s.Display)
+
+	// Unset and invalid preferences fall back to UTC.
+	for _, zone := range []string{"", "Local", "Mars/Olympus"} {
+		s := In(at, zone)
+		assert.Equal(t, "UTC", s.TimeZone, zone)
+		assert.Equal(t, "2026-03-01T22:30:00Z", s.Local, zone)
+	}
+}
+
+func TestValidZone(t *testing.T) {
+	assert.True(t, ValidZone("Africa/Blantyre"))
+	assert.True(t, ValidZone("UTC"))
+	assert.False(t, ValidZone(""))
+	assert.False(t, ValidZone("local"))
+	assert.False(t, ValidZone("GMT+2"))
+}
+
+func TestBusinessZoneDecidesWeekend(t *testing.T) {
+	require.Error(t, Init("Nowhere/Special"))
+	require.NoError(t, Init("Asia/Shanghai"))
+	defer Init(UTCName)
+
+	// Friday 20:00 UTC is already Saturday in Shanghai.
+	friday := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	assert.True(t, IsWeekend(friday))
+	assert.Equal(t, time.UTC, time.Local)
+	assert.Equal(t, time.UTC, Now().Location())
+}
@@ -3,6 +3,7 @@ package config
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -30,5 +31,18 @@ func (c *Config) ValidateCore() error {
 		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
 	}
 
+	for _, mode := range []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"REDIS_RATE_LIMIT_FAILURE", c.Redis.RateLimitFailure, []string{"open", "closed", "local"}},
+		{"REDIS_IDEMPOTENCY_FAILURE", c.Redis.IdempotencyFailure, []string{"open", "closed", "local"}},
+		{"REDIS_TOKEN_CHECK_FAILURE", c.Redis.TokenCheckFailure, []string{"open", "closed", "local"}},
+	} {
+		if !slices.Contains(mode.allowed, mode.value) {
+			return fmt.Errorf("invalid %s %q: must be one of %s", mode.name, mode.value, strings.Join(mode.allowed, ", "))
+		}
+	}
+
 	return nil
 }
@@ -47,15 +47,29 @@ const (
 	CHF Currency = "CHF" // Swiss Franc
 )
 
+// MinorUnits is the number of decimal places amounts in c are kept to, as
+// ISO 4217 sets it: none for the yen, won, Ugandan shilling and Rwandan
+// franc, and cents for the rest.
+func (c Currency) MinorUnits() int32 {
+	switch c {
+	case JPY, KRW, UGX, RWF:
+		return 0
+	}
+	return 2
+}
+
 // User represents a system user
 type User struct {
 	ID                   uuid.UUID       `json:"id" db:"id"`
 	Email                string          `json:"email" db:"email"`
 	Phone                string          `json:"phone" db:"phone"`
 	PasswordHash         string          `json:"-" db:"password_hash"`
+	PasswordVersion      int             `json:"-" db:"password_policy_version"` // password policy the hash was last checked against
 	FirstName            string          `json:"first_name" db:"first_name"`
 	LastName             string          `json:"last_name" db:"last_name"`
 	UserType             UserType        `json:"user_type" db:"user_type"`
+	Tenant               string          `json:"tenant,omitempty" db:"tenant"`         // tenant the user was onboarded under; DefaultTenant unless set
+	AdminRole            AdminRole       `json:"admin_role,omitempty" db:"admin_role"` // what an admin may see unmasked; empty for non-admins
 	KYCLevel             int             `json:"kyc_level" db:"kyc_level"`
 	KYCStatus            KYCStatus       `json:"kyc_status" db:"kyc_status"`
 	UserStatus           UserStatus      `json:"user_status" db:"user_status"`
@@ -66,8 +80,12 @@ type User struct {
 	RiskScore            decimal.Decimal `json:"risk_score" db:"risk_score"`
 	IsActive             bool            `json:"is_active" db:"is_active"`
 	EmailVerified        bool            `json:"email_verified" db:"email_verified"`
+	PhoneVerified        bool            `json:"phone_verified" db:"phone_verified"`
+	PhoneVerifiedAt      *time.Time      `json:"phone_verified_at,omitempty" db:"phone_verified_at"`
 	TOTPSecret           *string         `json:"-" db:"totp_secret"`
 	IsTOTPEnabled        bool            `json:"is_totp_enabled" db:"is_totp_enabled"`
+	LoginAlertsDisabled  bool            `json:"login_alerts_disabled" db:"login_alerts_disabled"`
+	TimeZone             string          `json:"time_zone" db:"time_zone"` // IANA zone for presenting times; UTC when empty
 	Bio                  string          `json:"bio,omitempty" db:"bio"`
 	City                 string          `json:"city,omitempty" db:"city"`
 	PostalCode           string          `json:"postal_code,omitempty" db:"postal_code"`
@@ -84,6 +102,9 @@ type User struct {
 	UpdatedAt            time.Time       `json:"updated_at" db:"updated_at"`
 }
 
+// DefaultTenant is the tenant of users not onboarded under a specific one.
+const DefaultTenant = "default"
+
 type UserType string
 
 const (
@@ -93,6 +114,23 @@ const (
 	UserTypeAdmin      UserType = "admin"
 )
 
+// AdminRole narrows what an admin sees. Support staff get masked personal
+// data and must unmask record by record, with a reason; compliance and
+// super admins see everything.
+type AdminRole string
+
+const (
+	AdminRoleSupport    AdminRole = "support"
+	AdminRoleCompliance AdminRole = "compliance"
+	AdminRoleSuperAdmin AdminRole = "superadmin"
+)
+
+// SeesUnmaskedData reports whether admins with this role are shown personal
+// data in full. Unknown and empty roles are masked.
+func (r AdminRole) SeesUnmaskedData() bool {
+	return r == AdminRoleCompliance || r == AdminRoleSuperAdmin
+}
+
 type KYCStatus string
 
 const (
@@ -102,6 +140,37 @@ const (
 	KYCStatusRejected   KYCStatus = "rejected"
 )
 
+// KYCDocumentStatus is where one uploaded KYC document is in review.
+type KYCDocumentStatus string
+
+const (
+	KYCDocumentUploaded     KYCDocumentStatus = "uploaded"
+	KYCDocumentScanning     KYCDocumentStatus = "scanning"
+	KYCDocumentScannedClean KYCDocumentStatus = "scanned_clean"
+	KYCDocumentInReview     KYCDocumentStatus = "in_review"
+	KYCDocumentAccepted     KYCDocumentStatus = "accepted"
+	KYCDocumentRejected     KYCDocumentStatus = "rejected"
+	// KYCDocumentQuarantined documents failed the virus scan and are never
+	// shown to reviewers.
+	KYCDocumentQuarantined KYCDocumentStatus = "quarantined"
+	// KYCDocumentInfoRequested documents were sent back to the user by a
+	// reviewer asking for a better or different upload.
+	KYCDocumentInfoRequested KYCDocumentStatus = "info_requested"
+)
+
+// VirusScanStatus is the outcome of scanning a KYC document's files.
+type VirusScanStatus string
+
+const (
+	VirusScanPending  VirusScanStatus = "pending"
+	VirusScanClean    VirusScanStatus = "clean"
+	VirusScanInfected VirusScanStatus = "infected"
+	// VirusScanFailed means no verdict was reached, e.g. clamd was down.
+	VirusScanFailed VirusScanStatus = "failed"
+	// VirusScanSkipped marks documents uploaded while scanning was off.
+	VirusScanSkipped VirusScanStatus = "skipped"
+)
+
 type UserStatus string
 
 const (
@@ -113,13 +182,16 @@ const (
 
 // Wallet represents a user's currency wallet
 type Wallet struct {
-	ID                uuid.UUID       `json:"id" db:"id"`
-	UserID            uuid.UUID       `json:"user_id" db:"user_id"`
-	WalletAddress     *string         `json:"wallet_address,omitempty" db:"wallet_address"`
-	Currency          Currency        `json:"currency" db:"currency"`
-	AvailableBalance  decimal.Decimal `json:"available_balance" db:"available_balance"`
-	LedgerBalance     decimal.Decimal `json:"ledger_balance" db:"ledger_balance"`
-	ReservedBalance   decimal.Decimal `json:"reserved_balance" db:"reserved_balance"`
+	ID               uuid.UUID       `json:"id" db:"id"`
+	UserID           uuid.UUID       `json:"user_id" db:"user_id"`
+	WalletAddress    *string         `json:"wallet_address,omitempty" db:"wallet_address"`
+	Currency         Currency        `json:"currency" db:"currency"`
+	AvailableBalance decimal.Decimal `json:"available_balance" db:"available_balance"`
+	LedgerBalance    decimal.Decimal `json:"ledger_balance" db:"ledger_balance"`
+	ReservedBalance  decimal.Decimal `json:"reserved_balance" db:"reserved_balance"`
+	// OverdraftLimit is how far below zero payments may take the balance,
+	// set by the wallet's credit facility.
+	OverdraftLimit    decimal.Decimal `json:"overdraft_limit" db:"overdraft_limit"`
 	Status            WalletStatus    `json:"status" db:"status"`
 	LastTransactionAt *time.Time      `json:"last_transaction_at,omitempty" db:"last_transaction_at"`
 	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
@@ -138,6 +210,7 @@ const (
 type Transaction struct {
 	ID                uuid.UUID         `json:"id" db:"id"`
 	Reference         string            `json:"reference" db:"reference"`
+	ReferenceNumber   string            `json:"reference_number,omitempty" db:"reference_number"` // human-readable, see pkg/reference
 	SenderID          uuid.UUID         `json:"sender_id" db:"sender_id"`
 	ReceiverID        uuid.UUID         `json:"receiver_id" db:"receiver_id"`
 	SenderWalletID    *uuid.UUID        `json:"sender_wallet_id" db:"sender_wallet_id"`
@@ -182,6 +255,7 @@ const (
 	TransactionStatusCancelled          TransactionStatus = "cancelled"
 	TransactionStatusRequiresReview     TransactionStatus = "requires_review"
 	TransactionStatusAdminInvestigation TransactionStatus = "admin_investigation"
+	TransactionStatusQueued             TransactionStatus = "queued_for_next_window"
 )
 
 type TransactionType string
@@ -199,6 +273,11 @@ const (
 	TransactionTypeRefund        TransactionType = "refund"
 	TransactionTypeReversal      TransactionType = "reversal"
 	TransactionTypeSettlement    TransactionType = "settlement"
+	TransactionTypeAdjustment    TransactionType = "adjustment"
+	TransactionTypeEscheatment   TransactionType = "escheatment"
+	// TransactionTypeCreditInterest collects interest accrued on an
+	// overdrawn wallet.
+	TransactionTypeCreditInterest TransactionType = "credit_interest"
 )
 
 // Metadata is a JSON-compatible map
@@ -388,15 +467,16 @@ type SystemHealthMetric struct {
 
 // Notification represents a user-facing notification in the notification center
 type Notification struct {
-	ID         uuid.UUID `json:"id" db:"id"`
-	UserID     uuid.UUID `json:"user_id" db:"user_id"`
-	Type       string    `json:"type" db:"type"`
-	Title      string    `json:"title" db:"title"`
-	Message    string    `json:"message" db:"message"`
-	Data       Metadata  `json:"data" db:"data"`
-	IsRead     bool      `json:"is_read" db:"is_read"`
-	IsArchived bool      `json:"is_archived" db:"is_archived"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Type       string     `json:"type" db:"type"`
+	Title      string     `json:"title" db:"title"`
+	Message    string     `json:"message" db:"message"`
+	Data       Metadata   `json:"data" db:"data"`
+	IsRead     bool       `json:"is_read" db:"is_read"`
+	ReadAt     *time.Time `json:"read_at,omitempty" db:"read_at"`
+	IsArchived bool       `json:"is_archived" db:"is_archived"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 }
 
 // TransactionVolume represents daily transaction volume
@@ -421,11 +501,15 @@ type SystemStats struct {
 }
 
 const (
-	SecurityEventTypeBruteForce         = "brute_force"
-	SecurityEventTypeSuspiciousIP       = "suspicious_ip"
-	SecurityEventTypeAdminLoginFailed   = "admin_login_failed"
-	SecurityEventTypeVelocityLimit      = "velocity_limit"
-	SecurityEventTypeBlockchainMismatch = "blockchain_mismatch"
+	SecurityEventTypeBruteForce           = "brute_force"
+	SecurityEventTypeSuspiciousIP         = "suspicious_ip"
+	SecurityEventTypeAdminLoginFailed     = "admin_login_failed"
+	SecurityEventTypeVelocityLimit        = "velocity_limit"
+	SecurityEventTypeBlockchainMismatch   = "blockchain_mismatch"
+	SecurityEventTypeLoginSuccess         = "login_success"
+	SecurityEventTypeMetricAnomaly        = "metric_anomaly"
+	SecurityEventTypeCounterpartyExposure = "counterparty_exposure"
+	SecurityEventTypeRedisDegraded        = "redis_degraded"
 
 	SecuritySeverityCritical = "critical"
 	SecuritySeverityHigh     = "high"
@@ -457,19 +541,48 @@ type KYCDocument struct {
 	Metadata           Metadata   `json:"metadata" db:"metadata"`
 	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+	// ReviewStatus tracks the document itself; VerificationStatus mirrors
+	// the application decision.
+	ReviewStatus    KYCDocumentStatus `json:"review_status" db:"review_status"`
+	RejectionReason *string           `json:"rejection_reason,omitempty" db:"rejection_reason"`
+	// ReplacesID links a re-upload to the attempt it replaces, which keeps
+	// its row with SupersededAt set.
+	ReplacesID   *uuid.UUID `json:"replaces_id,omitempty" db:"replaces_id"`
+	SupersededAt *time.Time `json:"superseded_at,omitempty" db:"superseded_at"`
+	// VirusScanStatus must be clean, or skipped, before the document can
+	// be verified.
+	VirusScanStatus VirusScanStatus `json:"virus_scan_status" db:"virus_scan_status"`
+	VirusSignature  *string         `json:"virus_signature,omitempty" db:"virus_signature"`
+	ScannedAt       *time.Time      `json:"scanned_at,omitempty" db:"scanned_at"`
+}
+
+// ScanCleared reports whether the document's files may be opened and the
+// document verified.
+func (d *KYCDocument) ScanCleared() bool {
+	return d.VirusScanStatus == VirusScanClean || d.VirusScanStatus == VirusScanSkipped
 }
 
+// APIEnvironment is the environment an API key works in.
+type APIEnvironment string
+
+const (
+	APIEnvironmentProduction APIEnvironment = "production"
+	APIEnvironmentSandbox    APIEnvironment = "sandbox"
+)
+
 // APIKey represents an administrative API key
 type APIKey struct {
-	ID         uuid.UUID  `json:"id" db:"id"`
-	Name       string     `json:"name" db:"name"`
-	KeyPrefix  string     `json:"key_prefix" db:"key_prefix"`
-	KeyHash    string     `json:"-" db:"key_hash"`
-	Scopes     []string   `json:"scopes" db:"scopes"`
-	IsActive   bool       `json:"is_active" db:"is_active"`
-	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
-	CreatedBy  uuid.UUID  `json:"created_by" db:"created_by"`
-	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
-	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
-	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+	ID          uuid.UUID      `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	KeyPrefix   string         `json:"key_prefix" db:"key_prefix"`
+	KeyHash     string         `json:"-" db:"key_hash"`
+	Scopes      []string       `json:"scopes" db:"scopes"`
+	IsActive    bool           `json:"is_active" db:"is_active"`
+	ExpiresAt   *time.Time     `json:"expires_at" db:"expires_at"`
+	CreatedBy   uuid.UUID      `json:"created_by" db:"created_by"`
+	OwnerID     *uuid.UUID     `json:"owner_id,omitempty" db:"owner_id"` // customer account the key acts for; nil for platform keys
+	Environment APIEnvironment `json:"environment" db:"environment"`
+	LastUsedAt  *time.Time     `json:"last_used_at" db:"last_used_at"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	RevokedAt   *time.Time     `json:"revoked_at" db:"revoked_at"`
 }
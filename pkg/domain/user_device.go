@@ -12,6 +12,7 @@ type UserDevice struct {
 	UserID      uuid.UUID `json:"user_id" db:"user_id"`
 	DeviceHash  string    `json:"device_hash" db:"device_hash"`
 	DeviceName  *string   `json:"device_name,omitempty" db:"device_name"`
+	Label       *string   `json:"label,omitempty" db:"label"` // Name the user gave it
 	CountryCode *string   `json:"country_code,omitempty" db:"country_code"`
 	IPAddress   *string   `json:"ip_address,omitempty" db:"ip_address"`
 	IsTrusted   bool      `json:"is_trusted" db:"is_trusted"`
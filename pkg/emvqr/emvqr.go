@@ -0,0 +1,243 @@
+// Package emvqr encodes and decodes the QR codes people scan to pay a
+// wallet. Payloads follow the EMVCo merchant-presented QR layout: two-digit
+// tags each followed by a two-digit length and the value, ending in a
+// CRC-16 of everything before it, so a misread code is caught before its
+// contents are used. The wallet address sits in a merchant account template
+// identified by GUI.
+package emvqr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// GUI identifies KYD wallet addresses among the account templates a code
+// may carry.
+const GUI = "mw.kyd.wallet"
+
+const (
+	tagFormat       = "00"
+	tagInitiation   = "01"
+	tagAccount      = "26"
+	tagCategory     = "52"
+	tagCurrency     = "53"
+	tagAmount       = "54"
+	tagCountry      = "58"
+	tagName         = "59"
+	tagCity         = "60"
+	tagAdditional   = "62"
+	tagCRC          = "63"
+	subGUI          = "00"
+	subAddress      = "01"
+	subReference    = "05"
+	staticCode      = "11"
+	dynamicCode     = "12"
+	maxNameLen      = 25
+	maxCityLen      = 15
+	maxReferenceLen = 25
+)
+
+var (
+	// ErrInvalid is returned for payloads that are not well-formed.
+	ErrInvalid = errors.New("invalid QR payload")
+	// ErrChecksum is returned when the payload's CRC does not match, as
+	// when it was misread or altered.
+	ErrChecksum = errors.New("QR payload checksum does not match")
+	// ErrUnsupported is returned for well-formed codes that are not for a
+	// KYD wallet, or are in a currency it does not hold.
+	ErrUnsupported = errors.New("QR code is not for a KYD wallet")
+)
+
+// numericCurrencies are the ISO 4217 numeric codes of the currencies
+// wallets hold.
+var numericCurrencies = map[string]string{
+	"MWK": "454", "CNY": "156", "ZMW": "967", "USD": "840",
+	"ZAR": "710", "KES": "404", "NGN": "566", "GHS": "936", "UGX": "800", "TZS": "834", "RWF": "646",
+	"INR": "356", "JPY": "392", "KRW": "410", "SGD": "702", "HKD": "344",
+	"EUR": "978", "GBP": "826", "CHF": "756",
+}
+
+// Payload is what a wallet's QR code carries. A code with an amount asks
+// for that amount; one without lets the payer choose.
+type Payload struct {
+	Address string
+	// Currency is the wallet's ISO 4217 alphabetic code.
+	Currency string
+	Amount   *decimal.Decimal
+	// Reference is the payee's reference for the payment, e.g. an order
+	// number.
+	Reference string
+	// Name is the account holder's name, for the payer to check.
+	Name    string
+	City    string
+	Country string
+}
+
+// Encode returns the payload as the text of a QR code. Name and City are
+// cut to the lengths the layout allows.
+func Encode(p *Payload) (string, error) {
+	currency, ok := numericCurrencies[p.Currency]
+	if !ok {
+		return "", fmt.Errorf("%w: currency %q", ErrUnsupported, p.Currency)
+	}
+	if p.Address == "" || len(p.Address) > 50 {
+		return "", fmt.Errorf("%w: address must be 1 to 50 characters", ErrInvalid)
+	}
+	if len(p.Reference) > maxReferenceLen {
+		return "", fmt.Errorf("%w: reference is longer than %d characters", ErrInvalid, maxReferenceLen)
+	}
+	var b strings.Builder
+	b.WriteString(field(tagFormat, "01"))
+	if p.Amount != nil {
+		b.WriteString(field(tagInitiation, dynamicCode))
+	} else {
+		b.WriteString(field(tagInitiation, staticCode))
+	}
+	b.WriteString(field(tagAccount, field(subGUI, GUI)+field(subAddress, p.Address)))
+	b.WriteString(field(tagCategory, "0000"))
+	b.WriteString(field(tagCurrency, currency))
+	if p.Amount != nil {
+		if !p.Amount.IsPositive() {
+			return "", fmt.Errorf("%w: amount must be positive", ErrInvalid)
+		}
+		amount := p.Amount.String()
+		if len(amount) > 13 {
+			return "", fmt.Errorf("%w: amount is too large", ErrInvalid)
+		}
+		b.WriteString(field(tagAmount, amount))
+	}
+	if p.Country != "" {
+		b.WriteString(field(tagCountry, p.Country))
+	}
+	if name := clip(p.Name, maxNameLen); name != "" {
+		b.WriteString(field(tagName, name))
+	}
+	if city := clip(p.City, maxCityLen); city != "" {
+		b.WriteString(field(tagCity, city))
+	}
+	if p.Reference != "" {
+		b.WriteString(field(tagAdditional, field(subReference, p.Reference)))
+	}
+	b.WriteString(tagCRC + "04")
+	b.WriteString(fmt.Sprintf("%04X", crc16(b.String())))
+	return b.String(), nil
+}
+
+// Decode reads the text of a wallet's QR code, checking its CRC.
+func Decode(s string) (*Payload, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 8 || s[len(s)-8:len(s)-4] != tagCRC+"04" {
+		return nil, fmt.Errorf("%w: no checksum", ErrInvalid)
+	}
+	want, err := strconv.ParseUint(s[len(s)-4:], 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: checksum is not hexadecimal", ErrInvalid)
+	}
+	if uint16(want) != crc16(s[:len(s)-4]) {
+		return nil, ErrChecksum
+	}
+	fields, err := parse(s[:len(s)-8])
+	if err != nil {
+		return nil, err
+	}
+	if fields[tagFormat] != "01" {
+		return nil, fmt.Errorf("%w: unknown payload format", ErrInvalid)
+	}
+
+	p := &Payload{Name: fields[tagName], City: fields[tagCity], Country: fields[tagCountry]}
+	for tag := 26; tag <= 51; tag++ {
+		account, ok := fields[strconv.Itoa(tag)]
+		if !ok {
+			continue
+		}
+		sub, err := parse(account)
+		if err != nil {
+			return nil, err
+		}
+		if sub[subGUI] == GUI {
+			p.Address = sub[subAddress]
+			break
+		}
+	}
+	if p.Address == "" {
+		return nil, ErrUnsupported
+	}
+	for alpha, numeric := range numericCurrencies {
+		if fields[tagCurrency] == numeric {
+			p.Currency = alpha
+		}
+	}
+	if p.Currency == "" {
+		return nil, fmt.Errorf("%w: currency %q", ErrUnsupported, fields[tagCurrency])
+	}
+	if v, ok := fields[tagAmount]; ok {
+		amount, err := decimal.NewFromString(v)
+		if err != nil || !amount.IsPositive() {
+			return nil, fmt.Errorf("%w: amount %q", ErrInvalid, v)
+		}
+		p.Amount = &amount
+	}
+	if v, ok := fields[tagAdditional]; ok {
+		sub, err := parse(v)
+		if err != nil {
+			return nil, err
+		}
+		p.Reference = sub[subReference]
+	}
+	return p, nil
+}
+
+// parse splits s into its fields by tag.
+func parse(s string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for len(s) > 0 {
+		if len(s) < 4 {
+			return nil, fmt.Errorf("%w: truncated field", ErrInvalid)
+		}
+		tag := s[:2]
+		n, err := strconv.Atoi(s[2:4])
+		if err != nil || n < 1 || len(s) < 4+n {
+			return nil, fmt.Errorf("%w: bad length for tag %s", ErrInvalid, tag)
+		}
+		if _, dup := fields[tag]; dup {
+			return nil, fmt.Errorf("%w: tag %s given twice", ErrInvalid, tag)
+		}
+		fields[tag] = s[4 : 4+n]
+		s = s[4+n:]
+	}
+	return fields, nil
+}
+
+func field(tag, value string) string {
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value)
+}
+
+// clip cuts s to at most n bytes without splitting a character.
+func clip(s string, n int) string {
+	s = strings.TrimSpace(s)
+	for len(s) > n {
+		r := []rune(s)
+		s = string(r[:len(r)-1])
+	}
+	return s
+}
+
+// crc16 is the CRC-16/CCITT-FALSE checksum EMVCo codes end in.
+func crc16(s string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
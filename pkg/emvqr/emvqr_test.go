@@ -0,0 +1,77 @@
+package emvqr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRC16(t *testing.T) {
+	// The CRC-16/CCITT-FALSE check value
+	assert.Equal(t, uint16(0x29B1), crc16("123456789"))
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	amount := decimal.RequireFromString("1500.50")
+	p := &Payload{
+		Address:   "4539102834756192",
+		Currency:  "MWK",
+		Amount:    &amount,
+		Reference: "ORDER-42",
+		Name:      "Chikondi Banda-Phiri of Lilongwe",
+		City:      "Lilongwe",
+		Country:   "MW",
+	}
+	code, err := Encode(p)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(code, "000201010212"))
+	assert.Contains(t, code, "5303454")
+	assert.Contains(t, code, "54061500.5")
+
+	got, err := Decode(code)
+	require.NoError(t, err)
+	assert.Equal(t, p.Address, got.Address)
+	assert.Equal(t, "MWK", got.Currency)
+	require.NotNil(t, got.Amount)
+	assert.True(t, amount.Equal(*got.Amount))
+	assert.Equal(t, "ORDER-42", got.Reference)
+	assert.Equal(t, "Chikondi Banda-Phiri of L", got.Name)
+	assert.Equal(t, "Lilongwe", got.City)
+	assert.Equal(t, "MW", got.Country)
+
+	// Without an amount the code is static and the payer chooses
+	code, err = Encode(&Payload{Address: "4539102834756192", Currency: "USD"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(code, "000201010211"))
+	got, err = Decode(code)
+	require.NoError(t, err)
+	assert.Nil(t, got.Amount)
+	assert.Empty(t, got.Reference)
+}
+
+func TestDecodeRejects(t *testing.T) {
+	code, err := Encode(&Payload{Address: "4539102834756192", Currency: "MWK", Name: "Alice"})
+	require.NoError(t, err)
+
+	_, err = Decode(strings.Replace(code, "4539", "4593", 1))
+	assert.ErrorIs(t, err, ErrChecksum)
+	_, err = Decode(code[:len(code)-6])
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = Decode("not a code")
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	// A well-formed code for another scheme
+	body := field(tagFormat, "01") + field(tagInitiation, staticCode) +
+		field("29", field(subGUI, "com.example.pay")+field(subAddress, "123")) + field(tagCurrency, "454") + tagCRC + "04"
+	_, err = Decode(body + fmt.Sprintf("%04X", crc16(body)))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = Encode(&Payload{Address: "4539102834756192", Currency: "XYZ"})
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = Encode(&Payload{Address: "4539102834756192", Currency: "MWK", Reference: strings.Repeat("x", 26)})
+	assert.ErrorIs(t, err, ErrInvalid)
+}
@@ -13,6 +13,9 @@ var (
 	ErrInvalidCredentials       = errors.New("invalid credentials")
 	ErrWalletNotFound           = errors.New("wallet not found")
 	ErrWalletAlreadyExists      = errors.New("wallet already exists")
+	ErrWalletNotActive          = errors.New("wallet is not active")
+	ErrMovementFrozen           = errors.New("money movement is frozen")
+	ErrJurisdictionRestricted   = errors.New("jurisdiction is restricted")
 	ErrInsufficientBalance      = errors.New("insufficient balance")
 	ErrTransactionNotFound      = errors.New("transaction not found")
 	ErrTransactionAlreadyExists = errors.New("transaction already exists")
@@ -22,6 +25,16 @@ var (
 	ErrCurrencyNotAllowed       = errors.New("currency not allowed for user country")
 	ErrTOTPRequired             = errors.New("mfa required")
 	ErrInvalidTOTP              = errors.New("invalid mfa code")
+	ErrEmailNotVerified         = errors.New("email verification required")
+	ErrVerificationThrottled    = errors.New("verification recently sent")
+	ErrPhoneNotVerified         = errors.New("phone verification required")
+	ErrInvalidVerificationCode  = errors.New("invalid or expired verification code")
+	ErrTooManyCodeAttempts      = errors.New("too many verification attempts")
+	ErrStepUpLocked             = errors.New("too many failed verification attempts, try again later")
+	ErrBulkFileNotFound         = errors.New("bulk file not found")
+	ErrDuplicateBulkFile        = errors.New("bulk file was already received")
+	ErrInvalidAPIKey            = errors.New("invalid api key")
+	ErrAPIKeyRefused            = errors.New("api key refused")
 )
 
 // New returns a new error with the given text
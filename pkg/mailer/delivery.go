@@ -0,0 +1,342 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"kyd/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// DeliveryStatus is where a tracked message is in delivery.
+type DeliveryStatus string
+
+const (
+	StatusQueued     DeliveryStatus = "queued"     // waiting for its first or next attempt
+	StatusSent       DeliveryStatus = "sent"       // accepted by the mail server
+	StatusBounced    DeliveryStatus = "bounced"    // the recipient address was refused
+	StatusFailed     DeliveryStatus = "failed"     // gave up: permanent error or out of attempts
+	StatusSuppressed DeliveryStatus = "suppressed" // not sent: the recipient bounced before
+)
+
+// Kinds of tracked email. Critical kinds are the ones an admin resends when
+// delivery fails.
+const (
+	KindVerification  = "email_verification"
+	KindPasswordReset = "password_reset"
+	KindLoginAlert    = "login_alert"
+	KindAppeal        = "eligibility_appeal"
+	KindMFACode       = "mfa_code"
+	KindInvitation    = "invitation"
+)
+
+// IsCritical reports whether a kind of email is needed to use the account.
+func IsCritical(kind string) bool {
+	return kind == KindVerification || kind == KindPasswordReset || kind == KindInvitation
+}
+
+// ErrSuppressed is returned for mail to an address on the suppression list.
+var ErrSuppressed = errors.New("recipient is suppressed after a hard bounce")
+
+// ErrMessageNotFound is returned by a Store for an unknown message ID.
+var ErrMessageNotFound = errors.New("email message not found")
+
+// Message is a tracked email. Body is stored for retries but never listed.
+type Message struct {
+	ID            uuid.UUID      `json:"id"`
+	Kind          string         `json:"kind"`
+	Critical      bool           `json:"critical"`
+	To            string         `json:"to"`
+	Subject       string         `json:"subject"`
+	Body          string         `json:"-"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	LastError     string         `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time     `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time     `json:"sent_at,omitempty"`
+	ResentFrom    *uuid.UUID     `json:"resent_from,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// MessageFilter selects messages to list.
+type MessageFilter struct {
+	Status       DeliveryStatus
+	CriticalOnly bool
+	Limit        int
+	Offset       int
+}
+
+// Store persists tracked messages and the suppression list.
+type Store interface {
+	Create(ctx context.Context, m *Message) error
+	Update(ctx context.Context, m *Message) error
+	Get(ctx context.Context, id uuid.UUID) (*Message, error)
+	List(ctx context.Context, f MessageFilter) ([]*Message, int, error)
+	// ClaimDue returns up to limit queued messages due by now and pushes
+	// their next attempt out by lease, so other instances skip them.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Message, error)
+	IsSuppressed(ctx context.Context, email string) (bool, error)
+	Suppress(ctx context.Context, email, reason string) error
+	Unsuppress(ctx context.Context, email string) error
+}
+
+// RetryPolicy bounds retries of transient failures. Attempt n waits
+// BaseDelay*2^(n-1), capped at MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy retries for about an hour.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 6, BaseDelay: 30 * time.Second, MaxDelay: 30 * time.Minute}
+
+func (p RetryPolicy) backoff(attempts int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempts && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// WithTracking records every message in store, retries transient failures
+// under policy and suppresses addresses that hard-bounce.
+func (m *Mailer) WithTracking(store Store, policy RetryPolicy) *Mailer {
+	m.store = store
+	m.policy = policy
+	return m
+}
+
+// Tracking reports whether messages are tracked.
+func (m *Mailer) Tracking() bool {
+	return m.store != nil
+}
+
+// SendKind sends a message of the given kind. When tracked, a transient
+// failure schedules a retry and is not returned as an error; a bounce, a
+// permanent failure or a suppressed recipient is.
+func (m *Mailer) SendKind(ctx context.Context, kind, to, subject, body string) error {
+	if m.store == nil {
+		return m.sender.Send(to, subject, body)
+	}
+	now := m.now()
+	msg := &Message{
+		ID:        uuid.New(),
+		Kind:      kind,
+		Critical:  IsCritical(kind),
+		To:        to,
+		Subject:   subject,
+		Body:      body,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if id, ok := ctx.Value(resentFromKey{}).(uuid.UUID); ok {
+		msg.ResentFrom = &id
+	}
+	return m.deliver(ctx, msg)
+}
+
+type resentFromKey struct{}
+
+// WithResentFrom marks mail sent with ctx as a resend of message id, for
+// callers that rebuild a message rather than copy it with Resend.
+func WithResentFrom(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, resentFromKey{}, id)
+}
+
+func (m *Mailer) deliver(ctx context.Context, msg *Message) error {
+	suppressed, err := m.store.IsSuppressed(ctx, msg.To)
+	if err == nil && suppressed {
+		msg.Status, msg.LastError = StatusSuppressed, ErrSuppressed.Error()
+	}
+	if err := m.store.Create(ctx, msg); err != nil {
+		// Tracking is best effort; the mail itself still goes out.
+		return m.sender.Send(msg.To, msg.Subject, msg.Body)
+	}
+	if msg.Status == StatusSuppressed {
+		return ErrSuppressed
+	}
+	return m.attempt(ctx, msg)
+}
+
+// attempt sends msg once and records the outcome.
+func (m *Mailer) attempt(ctx context.Context, msg *Message) error {
+	msg.Attempts++
+	sendErr := m.sender.Send(msg.To, msg.Subject, msg.Body)
+	now := m.now()
+	msg.UpdatedAt = now
+	msg.NextAttemptAt = nil
+	switch {
+	case sendErr == nil:
+		msg.Status, msg.SentAt, msg.LastError = StatusSent, &now, ""
+	case isBounce(sendErr):
+		msg.Status, msg.LastError = StatusBounced, sendErr.Error()
+		_ = m.store.Suppress(ctx, msg.To, sendErr.Error())
+	case isPermanent(sendErr) || msg.Attempts >= m.policy.MaxAttempts:
+		msg.Status, msg.LastError = StatusFailed, sendErr.Error()
+	default:
+		next := now.Add(m.policy.backoff(msg.Attempts))
+		msg.Status, msg.LastError, msg.NextAttemptAt = StatusQueued, sendErr.Error(), &next
+		sendErr = nil
+	}
+	_ = m.store.Update(ctx, msg)
+	return sendErr
+}
+
+// RetryDue makes the next attempt at every queued message that is due and
+// returns how many were attempted.
+func (m *Mailer) RetryDue(ctx context.Context, limit int) (int, error) {
+	if m.store == nil {
+		return 0, nil
+	}
+	due, err := m.store.ClaimDue(ctx, m.now(), retryLease, limit)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range due {
+		if suppressed, err := m.store.IsSuppressed(ctx, msg.To); err == nil && suppressed {
+			msg.Status, msg.LastError, msg.NextAttemptAt, msg.UpdatedAt = StatusSuppressed, ErrSuppressed.Error(), nil, m.now()
+			_ = m.store.Update(ctx, msg)
+			continue
+		}
+		_ = m.attempt(ctx, msg)
+	}
+	return len(due), nil
+}
+
+// Resend sends a copy of a stored message as a new tracked message. With
+// liftSuppression the recipient is first removed from the suppression list.
+func (m *Mailer) Resend(ctx context.Context, id uuid.UUID, liftSuppression bool) (*Message, error) {
+	if m.store == nil {
+		return nil, errors.New("email tracking is not configured")
+	}
+	old, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if liftSuppression {
+		if err := m.store.Unsuppress(ctx, old.To); err != nil {
+			return nil, err
+		}
+	}
+	now := m.now()
+	msg := &Message{
+		ID:         uuid.New(),
+		Kind:       old.Kind,
+		Critical:   old.Critical,
+		To:         old.To,
+		Subject:    old.Subject,
+		Body:       old.Body,
+		Status:     StatusQueued,
+		ResentFrom: &old.ID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	err = m.deliver(ctx, msg)
+	return msg, err
+}
+
+// Message returns a stored message.
+func (m *Mailer) Message(ctx context.Context, id uuid.UUID) (*Message, error) {
+	if m.store == nil {
+		return nil, errors.New("email tracking is not configured")
+	}
+	return m.store.Get(ctx, id)
+}
+
+// Messages lists stored messages, newest first.
+func (m *Mailer) Messages(ctx context.Context, f MessageFilter) ([]*Message, int, error) {
+	if m.store == nil {
+		return []*Message{}, 0, nil
+	}
+	return m.store.List(ctx, f)
+}
+
+// Unsuppress removes an address from the suppression list.
+func (m *Mailer) Unsuppress(ctx context.Context, email string) error {
+	if m.store == nil {
+		return nil
+	}
+	return m.store.Unsuppress(ctx, email)
+}
+
+func (m *Mailer) now() time.Time {
+	if m.clock != nil {
+		return m.clock()
+	}
+	return time.Now().UTC()
+}
+
+// retryLease is how long a claimed message is hidden from other instances
+// while it is being attempted.
+const retryLease = 2 * time.Minute
+
+// isBounce reports whether the server refused the recipient address itself:
+// mailbox unavailable (550), user not local (551) or mailbox name not
+// allowed (553).
+func isBounce(err error) bool {
+	var tp *textproto.Error
+	if !errors.As(err, &tp) {
+		return false
+	}
+	return tp.Code == 550 || tp.Code == 551 || tp.Code == 553
+}
+
+// isPermanent reports whether retrying cannot help: any other 5xx reply,
+// such as rejected credentials or a message the server will not accept.
+func isPermanent(err error) bool {
+	var tp *textproto.Error
+	return errors.As(err, &tp) && tp.Code >= 500
+}
+
+// RetryWorker retries queued messages on an interval.
+type RetryWorker struct {
+	mailer   *Mailer
+	interval time.Duration
+	batch    int
+	logger   logger.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRetryWorker returns a worker that retries up to batch due messages
+// every interval.
+func NewRetryWorker(m *Mailer, interval time.Duration, batch int, log logger.Logger) *RetryWorker {
+	return &RetryWorker{mailer: m, interval: interval, batch: batch, logger: log, stop: make(chan struct{})}
+}
+
+func (w *RetryWorker) Start() {
+	if !w.mailer.Tracking() || w.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), w.interval)
+				if _, err := w.mailer.RetryDue(ctx, w.batch); err != nil {
+					w.logger.Error("Email retry failed", map[string]interface{}{"error": err.Error()})
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func (w *RetryWorker) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
@@ -0,0 +1,237 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	msgs       map[uuid.UUID]*Message
+	suppressed map[string]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{msgs: make(map[uuid.UUID]*Message), suppressed: make(map[string]string)}
+}
+
+func (s *memStore) Create(ctx context.Context, m *Message) error {
+	cp := *m
+	s.msgs[m.ID] = &cp
+	return nil
+}
+
+func (s *memStore) Update(ctx context.Context, m *Message) error {
+	cp := *m
+	s.msgs[m.ID] = &cp
+	return nil
+}
+
+func (s *memStore) Get(ctx context.Context, id uuid.UUID) (*Message, error) {
+	m, ok := s.msgs[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	cp := *m
+	return &cp, nil
+}
+
+func (s *memStore) List(ctx context.Context, f MessageFilter) ([]*Message, int, error) {
+	var out []*Message
+	for _, m := range s.msgs {
+		if f.Status == "" || m.Status == f.Status {
+			out = append(out, m)
+		}
+	}
+	return out, len(out), nil
+}
+
+func (s *memStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Message, error) {
+	var out []*Message
+	for _, m := range s.msgs {
+		if m.Status == StatusQueued && m.NextAttemptAt != nil && !m.NextAttemptAt.After(now) && len(out) < limit {
+			next := now.Add(lease)
+			m.NextAttemptAt = &next
+			cp := *m
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStore) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	_, ok := s.suppressed[strings.ToLower(email)]
+	return ok, nil
+}
+
+func (s *memStore) Suppress(ctx context.Context, email, reason string) error {
+	s.suppressed[strings.ToLower(email)] = reason
+	return nil
+}
+
+func (s *memStore) Unsuppress(ctx context.Context, email string) error {
+	delete(s.suppressed, strings.ToLower(email))
+	return nil
+}
+
+// scriptedSender returns the queued errors in order, then succeeds.
+type scriptedSender struct {
+	errs []error
+	sent int
+}
+
+func (s *scriptedSender) Send(to, subject, body string) error {
+	s.sent++
+	if len(s.errs) == 0 {
+		return nil
+	}
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+func newTrackedMailer(sender Sender) (*Mailer, *memStore, *time.Time) {
+	store := newMemStore()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := &Mailer{sender: sender, clock: func() time.Time { return now }}
+	m.WithTracking(store, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: 3 * time.Minute})
+	return m, store, &now
+}
+
+func only(t *testing.T, s *memStore) *Message {
+	require.Len(t, s.msgs, 1)
+	for _, m := range s.msgs {
+		return m
+	}
+	return nil
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Minute, MaxDelay: 5 * time.Minute}
+	assert.Equal(t, time.Minute, p.backoff(1))
+	assert.Equal(t, 2*time.Minute, p.backoff(2))
+	assert.Equal(t, 4*time.Minute, p.backoff(3))
+	assert.Equal(t, 5*time.Minute, p.backoff(4))
+	assert.Equal(t, 5*time.Minute, p.backoff(20))
+}
+
+func TestSendKind_Sent(t *testing.T) {
+	m, store, _ := newTrackedMailer(&scriptedSender{})
+
+	require.NoError(t, m.SendKind(context.Background(), KindVerification, "a@example.com", "Verify", "body"))
+
+	msg := only(t, store)
+	assert.Equal(t, StatusSent, msg.Status)
+	assert.True(t, msg.Critical)
+	assert.Equal(t, 1, msg.Attempts)
+	assert.NotNil(t, msg.SentAt)
+}
+
+func TestSendKind_TransientFailureRetriesUntilSent(t *testing.T) {
+	sender := &scriptedSender{errs: []error{
+		&textproto.Error{Code: 421, Msg: "try again later"},
+		errors.New("connection reset"),
+	}}
+	m, store, now := newTrackedMailer(sender)
+	ctx := context.Background()
+
+	require.NoError(t, m.SendKind(ctx, KindPasswordReset, "a@example.com", "Reset", "body"), "a transient failure is queued, not returned")
+	msg := only(t, store)
+	assert.Equal(t, StatusQueued, msg.Status)
+	assert.Equal(t, now.Add(time.Minute), *msg.NextAttemptAt)
+
+	n, err := m.RetryDue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n, "not due yet")
+
+	*now = now.Add(time.Minute)
+	_, err = m.RetryDue(ctx, 10)
+	require.NoError(t, err)
+	msg = only(t, store)
+	assert.Equal(t, StatusQueued, msg.Status)
+	assert.Equal(t, 2, msg.Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), *msg.NextAttemptAt, "the delay doubles")
+
+	*now = now.Add(2 * time.Minute)
+	_, err = m.RetryDue(ctx, 10)
+	require.NoError(t, err)
+	msg = only(t, store)
+	assert.Equal(t, StatusSent, msg.Status)
+	assert.Equal(t, 3, sender.sent)
+}
+
+func TestSendKind_GivesUpAfterMaxAttempts(t *testing.T) {
+	down := errors.New("dial tcp: connection refused")
+	m, store, now := newTrackedMailer(&scriptedSender{errs: []error{down, down, down, down}})
+	ctx := context.Background()
+
+	require.NoError(t, m.SendKind(ctx, KindVerification, "a@example.com", "Verify", "body"))
+	for i := 0; i < 5; i++ {
+		*now = now.Add(time.Hour)
+		_, err := m.RetryDue(ctx, 10)
+		require.NoError(t, err)
+	}
+
+	msg := only(t, store)
+	assert.Equal(t, StatusFailed, msg.Status)
+	assert.Equal(t, 3, msg.Attempts)
+	assert.Nil(t, msg.NextAttemptAt)
+}
+
+func TestSendKind_HardBounceSuppresses(t *testing.T) {
+	sender := &scriptedSender{errs: []error{&textproto.Error{Code: 550, Msg: "mailbox unavailable"}}}
+	m, store, _ := newTrackedMailer(sender)
+	ctx := context.Background()
+
+	err := m.SendKind(ctx, KindVerification, "gone@example.com", "Verify", "body")
+	require.Error(t, err)
+	assert.Equal(t, StatusBounced, only(t, store).Status)
+	assert.NotEmpty(t, store.suppressed["gone@example.com"])
+
+	err = m.SendKind(ctx, KindLoginAlert, "Gone@example.com", "Alert", "body")
+	assert.ErrorIs(t, err, ErrSuppressed)
+	assert.Equal(t, 1, sender.sent, "nothing is sent to a suppressed address")
+}
+
+func TestSendKind_PermanentFailureIsNotRetried(t *testing.T) {
+	m, store, _ := newTrackedMailer(&scriptedSender{errs: []error{&textproto.Error{Code: 535, Msg: "authentication failed"}}})
+
+	require.Error(t, m.SendKind(context.Background(), KindVerification, "a@example.com", "Verify", "body"))
+
+	msg := only(t, store)
+	assert.Equal(t, StatusFailed, msg.Status)
+	assert.Empty(t, store.suppressed, "only a refused recipient is suppressed")
+}
+
+func TestResend(t *testing.T) {
+	sender := &scriptedSender{errs: []error{&textproto.Error{Code: 550, Msg: "mailbox unavailable"}}}
+	m, store, _ := newTrackedMailer(sender)
+	ctx := context.Background()
+	require.Error(t, m.SendKind(ctx, KindLoginAlert, "a@example.com", "Alert", "body"))
+	bounced := only(t, store)
+
+	_, err := m.Resend(ctx, bounced.ID, false)
+	assert.ErrorIs(t, err, ErrSuppressed)
+
+	msg, err := m.Resend(ctx, bounced.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSent, msg.Status)
+	assert.Equal(t, bounced.ID, *msg.ResentFrom)
+	assert.Equal(t, "body", msg.Body)
+	assert.Empty(t, store.suppressed)
+}
+
+func TestSendKind_Untracked(t *testing.T) {
+	sender := &scriptedSender{errs: []error{errors.New("boom"), errors.New("boom")}}
+	m := &Mailer{sender: sender}
+
+	assert.Error(t, m.SendKind(context.Background(), KindVerification, "a@example.com", "Verify", "body"))
+	assert.Error(t, m.Send("a@example.com", "Verify", "body"), "without tracking, failures are returned as before")
+}
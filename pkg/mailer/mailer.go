@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/smtp"
 	"strings"
+	"time"
 
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
@@ -36,6 +37,11 @@ type Sender interface {
 type Mailer struct {
 	cfg    Config
 	sender Sender
+
+	// Delivery tracking; see WithTracking.
+	store  Store
+	policy RetryPolicy
+	clock  func() time.Time
 }
 
 // New creates a new Mailer instance.
@@ -64,9 +70,10 @@ func (m *Mailer) WithSender(sender Sender) *Mailer {
 	return m
 }
 
-// Send sends an email to the specified recipient.
+// Send sends an email to the specified recipient. Prefer SendKind so a
+// tracked message records what it was for.
 func (m *Mailer) Send(to, subject, body string) error {
-	return m.sender.Send(to, subject, body)
+	return m.SendKind(context.Background(), "", to, subject, body)
 }
 
 // NoopSender implements the Sender interface without sending anything.
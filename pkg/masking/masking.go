@@ -0,0 +1,72 @@
+// Package masking redacts personal data for staff who need to recognise a
+// record but not read it in full, e.g. support agents on a call. Masked
+// values keep just enough to confirm identity with the customer.
+package masking
+
+import (
+	"strings"
+	"unicode"
+)
+
+const stars = "***"
+
+// Email keeps the first character of the local part and the domain:
+// "jane.doe@example.com" becomes "j***@example.com".
+func Email(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return stars
+	}
+	r := []rune(local)
+	if len(r) == 0 {
+		return stars + "@" + domain
+	}
+	return string(r[0]) + stars + "@" + domain
+}
+
+// Phone keeps the last three digits: "+265 991 234 567" becomes "***567".
+func Phone(phone string) string {
+	return tail(digits(phone), 3)
+}
+
+// Account keeps the last four characters of a wallet or account number,
+// ignoring spacing: "1234 5678 9012 3456" becomes "***3456".
+func Account(number string) string {
+	compact := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' {
+			return -1
+		}
+		return r
+	}, number)
+	return tail(compact, 4)
+}
+
+// Name keeps the first name and the initials of the rest:
+// "Jane Mary Banda" becomes "Jane M. B.".
+func Name(full string) string {
+	fields := strings.Fields(full)
+	for i := 1; i < len(fields); i++ {
+		fields[i] = string([]rune(fields[i])[0]) + "."
+	}
+	return strings.Join(fields, " ")
+}
+
+func tail(s string, keep int) string {
+	if s == "" {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) <= keep {
+		return stars
+	}
+	return stars + string(r[len(r)-keep:])
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
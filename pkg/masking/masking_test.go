@@ -0,0 +1,31 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmail(t *testing.T) {
+	assert.Equal(t, "j***@example.com", Email("jane.doe@example.com"))
+	assert.Equal(t, "***@example.com", Email("@example.com"))
+	assert.Equal(t, "***", Email("not-an-email"))
+}
+
+func TestPhone(t *testing.T) {
+	assert.Equal(t, "***567", Phone("+265 991 234 567"))
+	assert.Equal(t, "***", Phone("12"))
+	assert.Equal(t, "", Phone(""))
+}
+
+func TestAccount(t *testing.T) {
+	assert.Equal(t, "***3456", Account("1234 5678 9012 3456"))
+	assert.Equal(t, "***", Account("123"))
+	assert.Equal(t, "", Account(""))
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "Jane M. B.", Name("Jane  Mary Banda"))
+	assert.Equal(t, "Jane", Name("Jane"))
+	assert.Equal(t, "", Name(" "))
+}
@@ -0,0 +1,203 @@
+// Package metrics exposes Prometheus metrics for every service on its
+// internal /metrics route: HTTP request counts and latency per route,
+// database pool statistics, Redis command latency and outages, requests
+// served without Redis, payment outcomes and settlement queue depth,
+// besides the Go runtime and process metrics.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kyd/pkg/config"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the metrics of this repository.
+const namespace = "kyd"
+
+// registry holds this process's metrics; it is what /metrics serves.
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route template and status code.",
+	}, []string{"service", "method", "route", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to serve HTTP requests, by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "method", "route"})
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Time for Redis commands and pipelines to complete.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command"})
+	redisErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_command_errors_total",
+		Help:      "Redis commands that failed, not counting missing keys.",
+	}, []string{"command"})
+	redisDegraded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "redis_degraded",
+		Help:      "1 while the service runs without Redis, its middleware in their failure modes.",
+	}, []string{"service"})
+	degradedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "degraded_requests_total",
+		Help:      "Requests middleware handled without Redis, by outcome: allowed unchecked, rejected, or checked locally.",
+	}, []string{"middleware", "outcome"})
+	payments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_total",
+		Help:      "Payments initiated, by the status they reached or failed.",
+	}, []string{"status", "currency"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, redisDuration, redisErrors, redisDegraded, degradedRequests, payments,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format. With a
+// username configured, scrapers must authenticate with basic auth.
+func Handler(cfg config.MetricsConfig) http.Handler {
+	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	if cfg.Username == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(cfg.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Middleware counts and times the requests of service per matched route
+// template, so requests for different IDs share a series.
+func Middleware(service string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			route := "unmatched"
+			if cr := mux.CurrentRoute(r); cr != nil {
+				if tmpl, err := cr.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+			httpRequests.WithLabelValues(service, r.Method, route, strconv.Itoa(rec.status)).Inc()
+			httpDuration.WithLabelValues(service, r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach flushing and hijacking.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades, which need an http.Hijacker, take over
+// the connection through the recorder.
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// RegisterDBPool exports the connection pool statistics of service's
+// database. Registering the same service again is a no-op.
+func RegisterDBPool(service string, db *sql.DB) {
+	register(&poolCollector{service: service, stats: db.Stats})
+}
+
+// ObservePayment counts the outcome of one payment initiation: the status
+// the payment reached, or failed when it was refused.
+func ObservePayment(status, currency string, err error) {
+	switch {
+	case err != nil:
+		status = "failed"
+	case status == "":
+		status = "unknown"
+	}
+	payments.WithLabelValues(status, currency).Inc()
+}
+
+// SetRedisDegraded flags whether service is running without Redis.
+func SetRedisDegraded(service string, degraded bool) {
+	v := 0.0
+	if degraded {
+		v = 1
+	}
+	redisDegraded.WithLabelValues(service).Set(v)
+}
+
+// ObserveDegraded counts a request middleware handled without Redis:
+// outcome is "allowed", "rejected" or "local".
+func ObserveDegraded(middleware, outcome string) {
+	degradedRequests.WithLabelValues(middleware, outcome).Inc()
+}
+
+// RegisterSettlementQueue exports the number of transactions waiting to be
+// settled, counted by depth at each scrape.
+func RegisterSettlementQueue(depth func(ctx context.Context) (int, error)) {
+	register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "settlement_queue_depth",
+		Help:      "Transactions waiting to be batched into a settlement; -1 when they could not be counted.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		n, err := depth(ctx)
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	}))
+}
+
+// register adds c unless an identical collector is already there, as when
+// services share a process.
+func register(c prometheus.Collector) {
+	if err := registry.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if !errors.As(err, &already) {
+			panic(err)
+		}
+	}
+}
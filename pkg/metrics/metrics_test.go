@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kyd/pkg/config"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, h http.Handler, user, pass string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Middleware("mw-test"))
+	r.HandleFunc("/api/v1/wallets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	for _, id := range []string{"1", "2", "3"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+id, nil))
+	}
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(httpRequests.WithLabelValues("mw-test", "GET", "/api/v1/wallets/{id}", "404")))
+	body := scrape(t, Handler(config.MetricsConfig{}), "", "").Body.String()
+	assert.Contains(t, body, `kyd_http_request_duration_seconds_count{method="GET",route="/api/v1/wallets/{id}",service="mw-test"} 3`)
+}
+
+func TestHandlerBasicAuth(t *testing.T) {
+	open := scrape(t, Handler(config.MetricsConfig{}), "", "")
+	require.Equal(t, http.StatusOK, open.Code)
+	assert.Contains(t, open.Body.String(), "go_goroutines")
+
+	h := Handler(config.MetricsConfig{Username: "prom", Password: "s3cret"})
+	assert.Equal(t, http.StatusUnauthorized, scrape(t, h, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, scrape(t, h, "prom", "wrong").Code)
+	assert.Equal(t, http.StatusOK, scrape(t, h, "prom", "s3cret").Code)
+}
+
+func TestPoolAndQueueCollectors(t *testing.T) {
+	stats := sql.DBStats{MaxOpenConnections: 25, InUse: 4, WaitCount: 7}
+	register(&poolCollector{service: "pool-test", stats: func() sql.DBStats { return stats }})
+	register(&poolCollector{service: "pool-test", stats: func() sql.DBStats { return stats }})
+
+	depth, fail := 12, false
+	RegisterSettlementQueue(func(ctx context.Context) (int, error) {
+		if fail {
+			return 0, errors.New("database down")
+		}
+		return depth, nil
+	})
+
+	body := scrape(t, Handler(config.MetricsConfig{}), "", "").Body.String()
+	assert.Contains(t, body, `kyd_db_pool_max_open_connections{service="pool-test"} 25`)
+	assert.Contains(t, body, `kyd_db_pool_in_use_connections{service="pool-test"} 4`)
+	assert.Contains(t, body, `kyd_db_pool_wait_count_total{service="pool-test"} 7`)
+	assert.Contains(t, body, "kyd_settlement_queue_depth 12")
+
+	fail = true
+	body = scrape(t, Handler(config.MetricsConfig{}), "", "").Body.String()
+	assert.Contains(t, body, "kyd_settlement_queue_depth -1")
+}
+
+func TestObservePayment(t *testing.T) {
+	before := testutil.ToFloat64(payments.WithLabelValues("failed", "MWK"))
+	ObservePayment("pending_settlement", "MWK", nil)
+	ObservePayment("", "MWK", errors.New("insufficient balance"))
+	assert.Equal(t, before+1, testutil.ToFloat64(payments.WithLabelValues("failed", "MWK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(payments.WithLabelValues("pending_settlement", "MWK")))
+	assert.False(t, strings.Contains(scrape(t, Handler(config.MetricsConfig{}), "", "").Body.String(), `status=""`))
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolMaxOpen           = poolDesc("max_open_connections", "Maximum number of open connections.")
+	poolOpen              = poolDesc("open_connections", "Established connections, in use and idle.")
+	poolInUse             = poolDesc("in_use_connections", "Connections currently in use.")
+	poolIdle              = poolDesc("idle_connections", "Idle connections.")
+	poolWaitCount         = poolDesc("wait_count_total", "Connections waited for.")
+	poolWaitSeconds       = poolDesc("wait_duration_seconds_total", "Time blocked waiting for a connection.")
+	poolMaxIdleClosed     = poolDesc("max_idle_closed_total", "Connections closed due to the idle limit.")
+	poolMaxIdleTimeClosed = poolDesc("max_idle_time_closed_total", "Connections closed due to the idle time limit.")
+	poolMaxLifetimeClosed = poolDesc("max_lifetime_closed_total", "Connections closed due to the lifetime limit.")
+)
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, []string{"service"}, nil)
+}
+
+// poolCollector reads a database pool's statistics at each scrape.
+type poolCollector struct {
+	service string
+	stats   func() sql.DBStats
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		poolMaxOpen, poolOpen, poolInUse, poolIdle, poolWaitCount, poolWaitSeconds,
+		poolMaxIdleClosed, poolMaxIdleTimeClosed, poolMaxLifetimeClosed,
+	} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, c.service)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, c.service)
+	}
+	gauge(poolMaxOpen, float64(s.MaxOpenConnections))
+	gauge(poolOpen, float64(s.OpenConnections))
+	gauge(poolInUse, float64(s.InUse))
+	gauge(poolIdle, float64(s.Idle))
+	counter(poolWaitCount, float64(s.WaitCount))
+	counter(poolWaitSeconds, s.WaitDuration.Seconds())
+	counter(poolMaxIdleClosed, float64(s.MaxIdleClosed))
+	counter(poolMaxIdleTimeClosed, float64(s.MaxIdleTimeClosed))
+	counter(poolMaxLifetimeClosed, float64(s.MaxLifetimeClosed))
+}
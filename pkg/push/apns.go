@@ -0,0 +1,145 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProduction = "https://api.push.apple.com"
+	apnsSandbox    = "https://api.sandbox.push.apple.com"
+
+	// Apple refuses provider tokens older than an hour and throttles
+	// refreshing them more often than every 20 minutes.
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsSender sends through the Apple Push Notification service with
+// token-based authentication: a .p8 signing key of the developer team.
+type APNsSender struct {
+	keyID    string
+	teamID   string
+	topic    string
+	key      *ecdsa.PrivateKey
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNsSender signs with the PEM-encoded key keyID of team teamID and
+// sends to the app whose bundle ID is topic. Production selects the
+// production service rather than the sandbox used by development builds.
+func NewAPNsSender(keyPEM []byte, keyID, teamID, topic string, production bool) (*APNsSender, error) {
+	key, err := jwt.ParseECPrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("apns key: %w", err)
+	}
+	endpoint := apnsSandbox
+	if production {
+		endpoint = apnsProduction
+	}
+	return &APNsSender{
+		keyID:    keyID,
+		teamID:   teamID,
+		topic:    topic,
+		key:      key,
+		endpoint: endpoint,
+		// APNs only speaks HTTP/2, which the default transport negotiates
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}, nil
+}
+
+type apnsAlert struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type apnsError struct {
+	Reason string `json:"reason"`
+}
+
+func (s *APNsSender) Send(ctx context.Context, token string, msg *Message) error {
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": apnsAlert{Title: msg.Title, Body: msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	bearer, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+bearer)
+	req.Header.Set("apns-topic", s.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("apns: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var e apnsError
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4<<10)).Decode(&e)
+	switch {
+	case resp.StatusCode == http.StatusGone, e.Reason == "BadDeviceToken", e.Reason == "Unregistered":
+		return ErrUnregistered
+	case e.Reason == "ExpiredProviderToken":
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("apns: status %d: %s", resp.StatusCode, e.Reason)
+}
+
+// providerToken returns the signed JWT that authenticates requests, reused
+// for apnsTokenLifetime.
+func (s *APNsSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.token != "" && now.Sub(s.issuedAt) < apnsTokenLifetime {
+		return s.token, nil
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.teamID,
+		"iat": now.Unix(),
+	})
+	t.Header["kid"] = s.keyID
+	signed, err := t.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("apns: failed to sign provider token: %w", err)
+	}
+	s.token, s.issuedAt = signed, now
+	return signed, nil
+}
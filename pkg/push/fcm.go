@@ -0,0 +1,110 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	fcmEndpoint = "https://fcm.googleapis.com"
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+// FCMSender sends through the Firebase Cloud Messaging HTTP v1 API,
+// authenticated as a service account of the Firebase project.
+type FCMSender struct {
+	projectID string
+	endpoint  string
+	client    *http.Client
+}
+
+// NewFCMSender authenticates with the service account key in
+// credentialsJSON, as downloaded from the Firebase console.
+func NewFCMSender(ctx context.Context, projectID string, credentialsJSON []byte) (*FCMSender, error) {
+	creds, err := google.CredentialsFromJSONWithType(ctx, credentialsJSON, google.ServiceAccount, fcmScope)
+	if err != nil {
+		return nil, fmt.Errorf("fcm credentials: %w", err)
+	}
+	if projectID == "" {
+		projectID = creds.ProjectID
+	}
+	client := oauth2.NewClient(ctx, creds.TokenSource)
+	client.Timeout = 10 * time.Second
+	return &FCMSender{projectID: projectID, endpoint: fcmEndpoint, client: client}, nil
+}
+
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      fcmAndroid        `json:"android"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmAndroid struct {
+	Priority string `json:"priority"`
+}
+
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (s *FCMSender) Send(ctx context.Context, token string, msg *Message) error {
+	body, err := json.Marshal(fcmRequest{Message: fcmMessage{
+		Token:        token,
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+		Android:      fcmAndroid{Priority: "high"},
+	}})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v1/projects/%s/messages:send", s.endpoint, s.projectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fcm: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var e fcmError
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	if e.Error.Status == "NOT_FOUND" {
+		return ErrUnregistered
+	}
+	for _, d := range e.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return ErrUnregistered
+		}
+	}
+	return fmt.Errorf("fcm: status %d: %s %s", resp.StatusCode, e.Error.Status, e.Error.Message)
+}
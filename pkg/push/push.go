@@ -0,0 +1,56 @@
+// Package push delivers notifications to mobile devices through Firebase
+// Cloud Messaging and the Apple Push Notification service.
+package push
+
+import (
+	"context"
+	"errors"
+
+	"kyd/pkg/logger"
+)
+
+// ErrUnregistered means the provider no longer accepts the device token,
+// because the app was uninstalled or the token rotated. The token should be
+// forgotten.
+var ErrUnregistered = errors.New("push token is no longer registered")
+
+// Message is what a push notification shows, with Data passed to the app
+// for deep links.
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Sender delivers a message to one device token.
+type Sender interface {
+	Send(ctx context.Context, token string, msg *Message) error
+}
+
+// LogSender writes messages to the log instead of delivering them. It stands
+// in for FCM and APNs in local and test environments.
+type LogSender struct {
+	logger logger.Logger
+}
+
+// NewLogSender creates a Sender that only logs.
+func NewLogSender(log logger.Logger) *LogSender {
+	return &LogSender{logger: log}
+}
+
+func (s *LogSender) Send(_ context.Context, token string, msg *Message) error {
+	s.logger.Info("Push Sent", map[string]interface{}{
+		"token": Mask(token),
+		"title": msg.Title,
+		"body":  msg.Body,
+	})
+	return nil
+}
+
+// Mask hides all but the last six characters of a device token.
+func Mask(token string) string {
+	if len(token) <= 6 {
+		return token
+	}
+	return "…" + token[len(token)-6:]
+}
@@ -0,0 +1,97 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFCMSend(t *testing.T) {
+	var got fcmRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/kyd-app/messages:send", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got.Message.Token == "stale" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+			return
+		}
+		if got.Message.Token == "busy" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":503,"status":"UNAVAILABLE","message":"try later"}}`))
+			return
+		}
+		w.Write([]byte(`{"name":"projects/kyd-app/messages/1"}`))
+	}))
+	defer srv.Close()
+	s := &FCMSender{projectID: "kyd-app", endpoint: srv.URL, client: srv.Client()}
+	msg := &Message{Title: "Payment Received", Body: "You received 10 MWK.", Data: map[string]string{"type": "PAYMENT_RECEIVED"}}
+
+	require.NoError(t, s.Send(context.Background(), "token-1", msg))
+	assert.Equal(t, "token-1", got.Message.Token)
+	assert.Equal(t, "Payment Received", got.Message.Notification.Title)
+	assert.Equal(t, "PAYMENT_RECEIVED", got.Message.Data["type"])
+
+	assert.ErrorIs(t, s.Send(context.Background(), "stale", msg), ErrUnregistered)
+	err := s.Send(context.Background(), "busy", msg)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnregistered)
+}
+
+func TestAPNsSend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	s, err := NewAPNsSender(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), "KEY123", "TEAM456", "com.kyd.app", false)
+	require.NoError(t, err)
+
+	var payload map[string]interface{}
+	var bearers []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "com.kyd.app", r.Header.Get("apns-topic"))
+		bearer := strings.TrimPrefix(r.Header.Get("authorization"), "bearer ")
+		bearers = append(bearers, bearer)
+		parsed, err := jwt.Parse(bearer, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil })
+		require.NoError(t, err)
+		assert.Equal(t, "KEY123", parsed.Header["kid"])
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer srv.Close()
+	s.endpoint, s.client = srv.URL, srv.Client()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	msg := &Message{Title: "KYC approved", Body: "You're verified.", Data: map[string]string{"type": "KYC_STATUS_CHANGED"}}
+
+	require.NoError(t, s.Send(context.Background(), "device-token", msg))
+	assert.Equal(t, "KYC_STATUS_CHANGED", payload["type"])
+	alert := payload["aps"].(map[string]interface{})["alert"].(map[string]interface{})
+	assert.Equal(t, "KYC approved", alert["title"])
+
+	assert.ErrorIs(t, s.Send(context.Background(), "gone", msg), ErrUnregistered)
+
+	// The provider token is reused until it nears Apple's one hour limit
+	now = now.Add(apnsTokenLifetime + time.Second)
+	require.NoError(t, s.Send(context.Background(), "device-token", msg))
+	require.Len(t, bearers, 3)
+	assert.Equal(t, bearers[0], bearers[1])
+	assert.NotEqual(t, bearers[1], bearers[2])
+}
@@ -0,0 +1,100 @@
+// Package reference generates the short reference numbers shown to people on
+// receipts, statements and support calls, e.g. KYD-7F3KQ-9XM2C. Internal
+// identifiers stay UUIDs; a reference number only ever points at one.
+//
+// References use Crockford's base32 alphabet, which has no I, L, O or U, so
+// they survive being read aloud or copied by hand, and end in a Luhn mod 32
+// check character that catches any single mistyped character and most
+// swapped neighbours before a lookup is made.
+package reference
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	// Prefix starts every reference number.
+	Prefix = "KYD"
+
+	alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	base     = len(alphabet)
+	// bodyLen random characters are followed by one check character.
+	bodyLen  = 9
+	groupLen = 5
+)
+
+// ErrInvalid is returned for input that is not a well-formed reference
+// number or whose check character does not match.
+var ErrInvalid = errors.New("invalid reference number")
+
+// New returns a random reference number in canonical form.
+func New() (string, error) {
+	body := make([]byte, bodyLen)
+	max := big.NewInt(int64(base))
+	for i := range body {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		body[i] = alphabet[n.Int64()]
+	}
+	return format(string(body) + string(checkChar(string(body)))), nil
+}
+
+// Parse normalises what a person typed or read out into canonical form. Case,
+// spaces, dashes and dots are ignored, the prefix is optional, and the
+// look-alikes O, I and L are read as 0, 1 and 1.
+func Parse(s string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		switch r {
+		case ' ', '-', '.', '_', '\t':
+			continue
+		case 'O':
+			r = '0'
+		case 'I', 'L':
+			r = '1'
+		}
+		b.WriteRune(r)
+	}
+	code := b.String()
+	if len(code) == len(Prefix)+bodyLen+1 {
+		code = strings.TrimPrefix(code, Prefix)
+	}
+	if len(code) != bodyLen+1 || strings.Trim(code, alphabet) != "" {
+		return "", ErrInvalid
+	}
+	if checkChar(code[:bodyLen]) != code[bodyLen] {
+		return "", ErrInvalid
+	}
+	return format(code), nil
+}
+
+// Valid reports whether s parses as a reference number.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+func format(code string) string {
+	return Prefix + "-" + code[:groupLen] + "-" + code[groupLen:]
+}
+
+// checkChar computes the Luhn mod N check character of body.
+func checkChar(body string) byte {
+	sum := 0
+	factor := 2
+	for i := len(body) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(alphabet, body[i])
+		sum += addend/base + addend%base
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+	}
+	return alphabet[(base-sum%base)%base]
+}
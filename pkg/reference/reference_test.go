@@ -0,0 +1,64 @@
+package reference
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIsCanonicalAndValid(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		ref, err := New()
+		require.NoError(t, err)
+		assert.Len(t, ref, len("KYD-XXXXX-XXXXX"))
+		assert.True(t, strings.HasPrefix(ref, "KYD-"))
+
+		parsed, err := Parse(ref)
+		require.NoError(t, err)
+		assert.Equal(t, ref, parsed)
+		seen[ref] = true
+	}
+	assert.Len(t, seen, 200)
+}
+
+func TestParseToleratesFormatting(t *testing.T) {
+	ref, err := New()
+	require.NoError(t, err)
+	code := strings.ReplaceAll(strings.TrimPrefix(ref, "KYD-"), "-", "")
+
+	for _, typed := range []string{
+		strings.ToLower(ref),
+		code,
+		" kyd " + code[:3] + " " + code[3:7] + "." + code[7:],
+		strings.NewReplacer("0", "o", "1", "l").Replace(ref),
+	} {
+		got, err := Parse(typed)
+		require.NoError(t, err, typed)
+		assert.Equal(t, ref, got, typed)
+	}
+}
+
+func TestParseRejectsTypos(t *testing.T) {
+	ref, err := New()
+	require.NoError(t, err)
+	code := []byte(strings.ReplaceAll(strings.TrimPrefix(ref, "KYD-"), "-", ""))
+
+	// Every single-character substitution is caught by the check character.
+	for i := range code {
+		for _, c := range []byte(alphabet) {
+			if c == code[i] {
+				continue
+			}
+			typo := append([]byte{}, code...)
+			typo[i] = c
+			assert.False(t, Valid(string(typo)), string(typo))
+		}
+	}
+
+	for _, bad := range []string{"", "KYD-", "KYD-12345-1234", "KYD-12345-12345X", "KYD-1234U-12345"} {
+		assert.False(t, Valid(bad), bad)
+	}
+}
@@ -0,0 +1,48 @@
+// Package sms delivers text messages to phone numbers.
+package sms
+
+import (
+	"context"
+
+	"kyd/pkg/logger"
+)
+
+// Sender defines the interface for sending text messages.
+type Sender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// LogSender writes messages to the log instead of delivering them. It stands
+// in for an SMS gateway in local and test environments.
+type LogSender struct {
+	logger logger.Logger
+}
+
+// NewLogSender creates a Sender that only logs.
+func NewLogSender(log logger.Logger) *LogSender {
+	return &LogSender{logger: log}
+}
+
+func (s *LogSender) Send(_ context.Context, to, message string) error {
+	s.logger.Info("SMS Sent", map[string]interface{}{
+		"to":   Mask(to),
+		"body": message,
+	})
+	return nil
+}
+
+// Mask hides all but the last four digits of a phone number.
+func Mask(phone string) string {
+	if len(phone) <= 4 {
+		return phone
+	}
+	masked := make([]byte, len(phone))
+	for i := range phone {
+		if i < len(phone)-4 && phone[i] >= '0' && phone[i] <= '9' {
+			masked[i] = '*'
+		} else {
+			masked[i] = phone[i]
+		}
+	}
+	return string(masked)
+}
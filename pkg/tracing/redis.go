@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook traces the commands of a Redis client; add it with
+// client.AddHook(tracing.RedisHook{}). A missing key is not an error.
+type RedisHook struct{}
+
+var redisSystem = attribute.String("db.system.name", "redis")
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := Start(ctx, "redis.dial", redisSystem)
+		conn, err := next(ctx, network, addr)
+		End(span, err)
+		return conn, err
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := Start(ctx, "redis "+cmd.Name(), redisSystem, attribute.String("db.operation.name", cmd.Name()))
+		err := next(ctx, cmd)
+		endRedis(span, err)
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := Start(ctx, "redis pipeline", redisSystem, attribute.Int("db.operation.batch.size", len(cmds)))
+		err := next(ctx, cmds)
+		endRedis(span, err)
+		return err
+	}
+}
+
+func endRedis(span trace.Span, err error) {
+	if err == redis.Nil {
+		err = nil
+	}
+	End(span, err)
+}
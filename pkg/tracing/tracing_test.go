@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kyd/pkg/config"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	_, err := Setup(context.Background(), config.TracingConfig{}, "test")
+	require.NoError(t, err)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exporter
+}
+
+func TestTracePropagatesThroughProxyHop(t *testing.T) {
+	spans := recordSpans(t)
+
+	// The backend continues the trace the caller sent.
+	var backendTrace string
+	backend := mux.NewRouter()
+	backend.Use(Middleware)
+	backend.HandleFunc("/api/v1/wallets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		backendTrace = TraceID(r.Context())
+		_, span := Start(r.Context(), "wallet.lookup")
+		End(span, errors.New("not found"))
+		w.WriteHeader(http.StatusNotFound)
+	})
+	srv := httptest.NewServer(backend)
+	defer srv.Close()
+
+	ctx, root := Start(context.Background(), "gateway")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/wallets/42", nil)
+	require.NoError(t, err)
+	res, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	root.End()
+
+	assert.Equal(t, TraceID(ctx), backendTrace)
+	names := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range spans.GetSpans().Snapshots() {
+		assert.Equal(t, root.SpanContext().TraceID(), s.SpanContext().TraceID(), s.Name())
+		names[s.Name()] = s
+	}
+	require.Contains(t, names, "GET /api/v1/wallets/{id}")
+	require.Contains(t, names, "wallet.lookup")
+	assert.Equal(t, codes.Error, names["wallet.lookup"].Status().Code)
+}
+
+func TestSetupRejectsUnknownProtocol(t *testing.T) {
+	_, err := Setup(context.Background(), config.TracingConfig{Enabled: true, Protocol: "zipkin"}, "test")
+	assert.Error(t, err)
+}
+
+func TestTraceIDWithoutSpan(t *testing.T) {
+	assert.Empty(t, TraceID(context.Background()))
+}
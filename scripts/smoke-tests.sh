@@ -1,9 +1,12 @@
 #!/bin/bash
 # Smoke tests for KYD Payment System
-# Usage: ./smoke-tests.sh [staging|production]
-# Expects GATEWAY_URL or uses default based on ENV
+# Usage: ./smoke-tests.sh [staging|production] [check ...]
+# Expects GATEWAY_URL or uses default based on ENV. When DATABASE_URL is set
+# the golden-path checks in cmd/smoke run as well (see `go run ./cmd/smoke -list`);
+# their JSON report is written to smoke-report.json.
 set -e
 ENV="${1:-staging}"
+shift || true
 if [ "$ENV" = "production" ]; then
   BASE="${GATEWAY_URL:-https://kydpay.com}"
 else
@@ -11,5 +14,17 @@ else
 fi
 echo "Running smoke tests for $ENV at $BASE"
 curl -sf "$BASE/health" | grep -q '"status"' && echo "Health OK" || { echo "Health check failed"; exit 1; }
-curl -sf "$BASE/api/v1/auth/login" -X POST -H "Content-Type: application/json" -d '{"email":"test@test.com","password":"test"}' >/dev/null 2>&1 || true
+if [ -z "$DATABASE_URL" ]; then
+  echo "DATABASE_URL not set; skipping golden-path checks"
+  echo "Smoke tests passed"
+  exit 0
+fi
+go build -o ./smoke ./cmd/smoke
+status=0
+./smoke -base-url "$BASE" "$@" > smoke-report.json || status=$?
+cat smoke-report.json
+if [ "$status" -ne 0 ]; then
+  echo "Smoke tests failed (exit $status)"
+  exit "$status"
+fi
 echo "Smoke tests passed"
@@ -97,16 +97,30 @@ Write-Host "`n4. Top-up wallet (+2500)..." -ForegroundColor Yellow
 try {
     $before = [decimal]$johnWallet.available_balance
     $depositBody = @{
-        amount = 2500
-        source_id = "verify-script"
+        amount = "2500"
         currency = $johnWallet.currency
+        method = "bank_transfer"
+        provider = "mock"
     } | ConvertTo-Json
     $idempotency = [guid]::NewGuid().ToString()
     $topupHeaders = @{
         Authorization = "Bearer $token"
         "Idempotency-Key" = $idempotency
     }
-    $null = Invoke-RestMethod -Uri "http://localhost:3001/api/v1/wallets/$johnWalletId/deposit" -Method Post -Body $depositBody -ContentType "application/json" -Headers $topupHeaders
+    $deposit = Invoke-RestMethod -Uri "http://localhost:3001/api/v1/wallets/$johnWalletId/deposit" -Method Post -Body $depositBody -ContentType "application/json" -Headers $topupHeaders
+    # Confirm it as the mock provider would, signed with FUNDING_MOCK_SECRET
+    $fundingSecret = if ($env:FUNDING_MOCK_SECRET) { $env:FUNDING_MOCK_SECRET } else { "dev-funding-secret" }
+    $callbackBody = @{
+        reference = $deposit.transaction.reference
+        status = "succeeded"
+        amount = "2500"
+        currency = $johnWallet.currency
+    } | ConvertTo-Json -Compress
+    $ts = [DateTimeOffset]::UtcNow.ToUnixTimeSeconds()
+    $hmac = New-Object System.Security.Cryptography.HMACSHA256
+    $hmac.Key = [Text.Encoding]::UTF8.GetBytes($fundingSecret)
+    $sig = -join ($hmac.ComputeHash([Text.Encoding]::UTF8.GetBytes("$ts.$callbackBody")) | ForEach-Object { $_.ToString("x2") })
+    $null = Invoke-RestMethod -Uri "http://localhost:3001/api/v1/deposits/callbacks/mock" -Method Post -Body $callbackBody -ContentType "application/json" -Headers @{ "KYD-Signature" = "t=$ts,v1=$sig" }
     $walletsAfterTopup = Invoke-RestMethod -Uri "http://localhost:3001/api/v1/wallets" -Method Get -Headers $headers
     $johnAfterTopup = [decimal]($walletsAfterTopup.wallets | Where-Object { $_.wallet_id -eq $johnWalletId } | Select-Object -First 1).available_balance
     Write-Host "   Top-up OK: $before -> $johnAfterTopup" -ForegroundColor Green
@@ -0,0 +1,230 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"kyd/internal/domain"
+	"kyd/internal/webhook"
+)
+
+const testPassword = "Integration-Pass-2026!"
+
+// user is a registered account with a session token.
+type user struct {
+	ID    uuid.UUID
+	Email string
+	token string
+}
+
+// call sends an authenticated JSON request to base and decodes the reply
+// into out when it is non-nil. Every write carries a fresh Idempotency-Key.
+func (u *user) call(t *testing.T, base, method, path string, body, out interface{}) int {
+	t.Helper()
+	var token string
+	if u != nil {
+		token = u.token
+	}
+	return request(t, base, token, method, path, body, out)
+}
+
+func request(t *testing.T, base, token, method, path string, body, out interface{}) int {
+	t.Helper()
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, base+path, rd)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if method != http.MethodGet {
+		req.Header.Set("Idempotency-Key", uuid.NewString())
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if out != nil && resp.StatusCode < 300 {
+		require.NoError(t, json.Unmarshal(raw, out), method+" "+path+": "+string(raw))
+	}
+	if resp.StatusCode >= 300 {
+		t.Logf("%s %s -> %d: %s", method, path, resp.StatusCode, raw)
+	}
+	return resp.StatusCode
+}
+
+// newCustomer registers an individual in Malawi, marks them KYC verified at
+// level and ages the account past the new-account risk rules, then logs in.
+func newCustomer(t *testing.T, level int) *user {
+	t.Helper()
+	u := register(t)
+	_, err := sys.app.DB.Exec(`
+		UPDATE customer_schema.users
+		SET kyc_status = 'verified', kyc_level = $2, email_verified = TRUE,
+			created_at = NOW() - INTERVAL '90 days'
+		WHERE id = $1`, u.ID, level)
+	require.NoError(t, err)
+	login(t, u)
+	return u
+}
+
+// newAdmin registers an account and promotes it to a superadmin holding
+// the admin role.
+func newAdmin(t *testing.T) *user {
+	t.Helper()
+	u := register(t)
+	_, err := sys.app.DB.Exec(`
+		UPDATE customer_schema.users
+		SET user_type = 'admin', admin_role = 'superadmin', email_verified = TRUE
+		WHERE id = $1`, u.ID)
+	require.NoError(t, err)
+	_, err = sys.app.DB.Exec(`
+		INSERT INTO admin_schema.user_roles (user_id, role) VALUES ($1, 'admin')
+		ON CONFLICT DO NOTHING`, u.ID)
+	require.NoError(t, err)
+	login(t, u)
+	return u
+}
+
+func register(t *testing.T) *user {
+	t.Helper()
+	email := fmt.Sprintf("it-%s@example.com", uuid.NewString()[:8])
+	var resp struct {
+		User domain.User `json:"user"`
+	}
+	status := request(t, sys.auth.URL, "", http.MethodPost, "/api/v1/auth/register", map[string]string{
+		"email":        email,
+		"phone":        fmt.Sprintf("+26599%07d", rand.Intn(10000000)),
+		"password":     testPassword,
+		"first_name":   "Integration",
+		"last_name":    "Tester",
+		"user_type":    "individual",
+		"country_code": "MW",
+	}, &resp)
+	require.Less(t, status, 300, "register "+email)
+	return &user{ID: resp.User.ID, Email: email}
+}
+
+// login refreshes the token so it carries the claims set by the seeding SQL.
+func login(t *testing.T, u *user) {
+	t.Helper()
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	status := request(t, sys.auth.URL, "", http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"email":    u.Email,
+		"password": testPassword,
+	}, &resp)
+	require.Equal(t, http.StatusOK, status, "login "+u.Email)
+	u.token = resp.AccessToken
+}
+
+// openWallet creates the user's MWK wallet and tops it up with amount.
+func openWallet(t *testing.T, u *user, amount int64) *domain.Wallet {
+	t.Helper()
+	var w domain.Wallet
+	status := u.call(t, sys.payment.URL, http.MethodPost, "/api/v1/wallets", map[string]string{"currency": "MWK"}, &w)
+	require.Less(t, status, 300, "create wallet")
+	if amount > 0 {
+		var deposit struct {
+			Transaction *domain.Transaction `json:"transaction"`
+		}
+		status = u.call(t, sys.payment.URL, http.MethodPost, "/api/v1/wallets/"+w.ID.String()+"/deposit", map[string]interface{}{
+			"amount":   decimal.NewFromInt(amount),
+			"currency": "MWK",
+			"method":   "bank_transfer",
+			"provider": "mock",
+		}, &deposit)
+		require.Equal(t, http.StatusAccepted, status, "deposit")
+		confirmDeposit(t, deposit.Transaction.Reference, amount)
+	}
+	return &w
+}
+
+// confirmDeposit posts the mock provider's signed callback for a deposit.
+func confirmDeposit(t *testing.T, reference string, amount int64) {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"reference": reference,
+		"status":    "succeeded",
+		"amount":    decimal.NewFromInt(amount),
+		"currency":  "MWK",
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, sys.payment.URL+"/api/v1/deposits/callbacks/mock", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(fundingSecret, time.Now(), body))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "confirm deposit")
+}
+
+// send pays amount MWK from sender to receiver over the API.
+func send(t *testing.T, sender, receiver *user, amount int64) *domain.Transaction {
+	t.Helper()
+	var resp struct {
+		Transaction *domain.Transaction `json:"transaction"`
+	}
+	status := sender.call(t, sys.payment.URL, http.MethodPost, "/api/v1/payments", map[string]interface{}{
+		"receiver_id": receiver.ID,
+		"amount":      decimal.NewFromInt(amount),
+		"currency":    "MWK",
+		"description": "integration payment",
+	}, &resp)
+	require.Equal(t, http.StatusOK, status, "send payment")
+	require.NotNil(t, resp.Transaction)
+	return resp.Transaction
+}
+
+// balances reads a wallet straight from the database.
+func balances(t *testing.T, walletID uuid.UUID) (available, reserved decimal.Decimal) {
+	t.Helper()
+	err := sys.app.DB.QueryRow(`
+		SELECT available_balance, reserved_balance FROM customer_schema.wallets WHERE id = $1`,
+		walletID).Scan(&available, &reserved)
+	require.NoError(t, err)
+	return available, reserved
+}
+
+func txStatus(t *testing.T, txID uuid.UUID) domain.TransactionStatus {
+	t.Helper()
+	var status domain.TransactionStatus
+	err := sys.app.DB.QueryRow(`SELECT status FROM customer_schema.transactions WHERE id = $1`, txID).Scan(&status)
+	require.NoError(t, err)
+	return status
+}
+
+// eventually polls cond for background workers (settlement batches).
+func eventually(t *testing.T, timeout time.Duration, cond func() bool, msg string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for !cond() {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out after %s: %s", timeout, msg)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,93 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// dockerPool connects to the Docker daemon the suite starts its throwaway
+// containers on. It is only used when DATABASE_URL and REDIS_URL are not
+// provided, and finds the daemon as the docker CLI does, from DOCKER_HOST
+// or the default socket; see README.md. It fails when no daemon answers.
+func dockerPool() (*dockertest.Pool, error) {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, fmt.Errorf("docker client: %w", err)
+	}
+	if err := pool.Client.Ping(); err != nil {
+		return nil, fmt.Errorf("docker daemon not reachable: %w", err)
+	}
+	return pool, nil
+}
+
+// container is a throwaway Docker container started on a pool.
+type container struct {
+	pool     *dockertest.Pool
+	resource *dockertest.Resource
+}
+
+// startContainer runs repository:tag with its exposed ports published on
+// random host ports. The container is removed when stopped, or by the
+// daemon if the suite dies first.
+func startContainer(pool *dockertest.Pool, repository, tag string, env ...string) (*container, error) {
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: repository,
+		Tag:        tag,
+		Env:        env,
+	}, func(hc *docker.HostConfig) {
+		hc.AutoRemove = true
+		hc.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s:%s: %w", repository, tag, err)
+	}
+	return &container{pool: pool, resource: resource}, nil
+}
+
+// hostAddr returns the host:port the container's port is published on.
+func (c *container) hostAddr(port string) (string, error) {
+	hostPort := c.resource.GetPort(port)
+	if hostPort == "" {
+		return "", fmt.Errorf("%s is not published", port)
+	}
+	return net.JoinHostPort("127.0.0.1", hostPort), nil
+}
+
+func (c *container) stop() {
+	_ = c.pool.Purge(c.resource)
+}
+
+// waitFor polls ready until it returns nil or the timeout expires.
+func waitFor(ctx context.Context, timeout time.Duration, ready func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := ready()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not ready after %s: %w", timeout, err)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// dialable is a readiness check for a TCP address.
+func dialable(addr string) func() error {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,257 @@
+//go:build integration
+
+// Package integration runs the money flows end to end against real Postgres
+// and Redis, with the auth, payment and settlement services booted
+// in-process behind httptest servers:
+//
+//	go test -tags=integration ./tests/integration/...
+//
+// DATABASE_URL and REDIS_URL point the suite at existing stores (CI provides
+// them as job services). Otherwise it starts throwaway postgres and redis
+// containers through the Docker API, and skips when there is no daemon,
+// unless RUN_INTEGRATION_TESTS=true asks for the suite to run. See
+// README.md.
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/lib/pq"
+	"github.com/ory/dockertest/v3"
+
+	"kyd/internal/ledger"
+	"kyd/internal/payment"
+	pgrepo "kyd/internal/repository/postgres"
+	"kyd/internal/security"
+	"kyd/internal/server"
+	"kyd/pkg/bootstrap"
+)
+
+const (
+	postgresImage, postgresTag = "postgres", "15-alpine"
+	redisImage, redisTag       = "redis", "7-alpine"
+
+	migrationsURL = "file://../../migrations"
+)
+
+// stack is the running system shared by every scenario.
+type stack struct {
+	app        *bootstrap.App
+	auth       *httptest.Server
+	payment    *httptest.Server
+	settlement *httptest.Server
+
+	// payments drives escrow flows directly on the service, including
+	// the expiry refund the worker runs.
+	payments *payment.Service
+}
+
+var sys *stack
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	ctx := context.Background()
+
+	dbURL, redisAddr := os.Getenv("DATABASE_URL"), os.Getenv("REDIS_URL")
+	var pool *dockertest.Pool
+	if dbURL == "" || redisAddr == "" {
+		var err error
+		if pool, err = dockerPool(); err != nil {
+			// CI asks for the suite explicitly and must not pass by skipping it
+			if os.Getenv("RUN_INTEGRATION_TESTS") == "true" {
+				fmt.Println("integration: DATABASE_URL/REDIS_URL not set and cannot start containers:", err)
+				return 1
+			}
+			fmt.Println("integration: DATABASE_URL/REDIS_URL not set and cannot start containers; skipping:", err)
+			return 0
+		}
+	}
+	if dbURL == "" {
+		pg, err := startContainer(pool, postgresImage, postgresTag, "POSTGRES_PASSWORD=postgres", "POSTGRES_DB=kyd_integration")
+		if err != nil {
+			fmt.Println("integration:", err)
+			return 1
+		}
+		defer pg.stop()
+		addr, err := pg.hostAddr("5432/tcp")
+		if err != nil {
+			fmt.Println("integration:", err)
+			return 1
+		}
+		dbURL = fmt.Sprintf("postgres://postgres:postgres@%s/kyd_integration?sslmode=disable", addr)
+	}
+	if redisAddr == "" {
+		rd, err := startContainer(pool, redisImage, redisTag)
+		if err != nil {
+			fmt.Println("integration:", err)
+			return 1
+		}
+		defer rd.stop()
+		if redisAddr, err = rd.hostAddr("6379/tcp"); err != nil {
+			fmt.Println("integration:", err)
+			return 1
+		}
+	}
+	// The services take a bare host:port.
+	redisAddr = strings.TrimPrefix(redisAddr, "redis://")
+
+	if err := waitFor(ctx, time.Minute, pingPostgres(dbURL)); err != nil {
+		fmt.Println("integration: postgres:", err)
+		return 1
+	}
+	if err := waitFor(ctx, 30*time.Second, dialable(redisAddr)); err != nil {
+		fmt.Println("integration: redis:", err)
+		return 1
+	}
+	if err := migrateUp(dbURL); err != nil {
+		fmt.Println("integration: migrations:", err)
+		return 1
+	}
+
+	configure(dbURL, redisAddr)
+	s, err := boot()
+	if err != nil {
+		fmt.Println("integration: boot:", err)
+		return 1
+	}
+	defer s.close()
+	sys = s
+
+	return m.Run()
+}
+
+// fundingSecret signs the mock provider's callbacks that top wallets up.
+const fundingSecret = "integration-funding-secret"
+
+// configure sets the environment the services read at boot. Values already
+// set by the caller win, except the stores chosen above.
+func configure(dbURL, redisAddr string) {
+	os.Setenv("DATABASE_URL", dbURL)
+	os.Setenv("REDIS_URL", redisAddr)
+	defaults := map[string]string{
+		"DB_SSL_MODE":               "disable",
+		"JWT_SECRET":                "integration-secret-at-least-32-characters",
+		"ENCRYPTION_KEY":            randomHex(32),
+		"HMAC_KEY":                  randomHex(32),
+		"BYPASS_EMAIL_VERIFICATION": "true",
+		"SMTP_HOST":                 "",
+		"SUPPORT_LOOKUP_ENABLED":    "false",
+		"FUNDING_PROVIDERS":         "mock",
+		"FUNDING_MOCK_SECRET":       fundingSecret,
+	}
+	for k, v := range defaults {
+		if _, ok := os.LookupEnv(k); !ok {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+// boot connects one app to the stores and mounts the services on it, the
+// way cmd/all runs them in-process.
+func boot() (*stack, error) {
+	app := bootstrap.New("integration")
+	app.ConnectDatabase()
+	app.ConnectRedis()
+	s := &stack{app: app}
+
+	authHandler, err := server.Auth(app)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	paymentHandler, err := server.Payment(app)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	settlementHandler, err := server.Settlement(app)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	s.auth = httptest.NewServer(authHandler)
+	s.payment = httptest.NewServer(paymentHandler)
+	s.settlement = httptest.NewServer(settlementHandler)
+
+	crypto, err := security.NewCryptoService()
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	db := app.DB
+	txRepo := pgrepo.NewTransactionRepository(db)
+	s.payments = payment.NewService(
+		txRepo,
+		pgrepo.NewWalletRepository(db),
+		nil, nil,
+		pgrepo.NewUserRepository(db, crypto),
+		nil,
+		pgrepo.NewAuditRepository(db, crypto),
+		pgrepo.NewSecurityRepository(db),
+		app.Logger,
+		app.Config,
+	).WithEscrows(txRepo, ledger.NewService(db, pgrepo.NewLedgerRepository(db)))
+	return s, nil
+}
+
+func (s *stack) close() {
+	for _, srv := range []*httptest.Server{s.auth, s.payment, s.settlement} {
+		if srv != nil {
+			srv.Close()
+		}
+	}
+	s.app.Shutdown()
+}
+
+func pingPostgres(dbURL string) func() error {
+	return func() error {
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.Ping()
+	}
+}
+
+func migrateUp(dbURL string) error {
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		return err
+	}
+	m, err := migrate.NewWithDatabaseInstance(migrationsURL, "postgres", driver)
+	if err != nil {
+		return err
+	}
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
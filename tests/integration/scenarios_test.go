@@ -0,0 +1,157 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"kyd/internal/domain"
+	"kyd/internal/payment"
+)
+
+func TestSendMoney(t *testing.T) {
+	alice, bob := newCustomer(t, 2), newCustomer(t, 1)
+	aliceWallet := openWallet(t, alice, 100000)
+	bobWallet := openWallet(t, bob, 0)
+
+	tx := send(t, alice, bob, 25000)
+	require.NotEqual(t, domain.TransactionStatusFailed, tx.Status)
+
+	senderAvailable, _ := balances(t, aliceWallet.ID)
+	receiverAvailable, _ := balances(t, bobWallet.ID)
+	debited := decimal.NewFromInt(25000).Add(tx.FeeAmount)
+	require.Equal(t, decimal.NewFromInt(100000).Sub(debited).String(), senderAvailable.String(), "sender balance")
+	require.Equal(t, "25000", receiverAvailable.String(), "receiver balance")
+
+	// The sender sees the payment in their history.
+	var history struct {
+		Transactions []map[string]interface{} `json:"transactions"`
+	}
+	require.Equal(t, http.StatusOK, alice.call(t, sys.payment.URL, http.MethodGet, "/api/v1/payments", nil, &history))
+	require.NotEmpty(t, history.Transactions)
+
+	// Sending more than the balance is refused and moves nothing.
+	var failed struct{}
+	status := alice.call(t, sys.payment.URL, http.MethodPost, "/api/v1/payments", map[string]interface{}{
+		"receiver_id": bob.ID,
+		"amount":      decimal.NewFromInt(1000000),
+		"currency":    "MWK",
+	}, &failed)
+	require.Equal(t, http.StatusBadRequest, status)
+	after, _ := balances(t, aliceWallet.ID)
+	require.Equal(t, senderAvailable.String(), after.String(), "balance moved on refused payment")
+}
+
+func TestEscrow(t *testing.T) {
+	ctx := context.Background()
+	buyer, seller := newCustomer(t, 2), newCustomer(t, 1)
+	buyerWallet := openWallet(t, buyer, 50000)
+	sellerWallet := openWallet(t, seller, 0)
+
+	// Release: the buyer confirms delivery and the seller is paid.
+	resp, err := sys.payments.CreateEscrow(ctx, &payment.EscrowRequest{
+		SenderID:   buyer.ID,
+		ReceiverID: seller.ID,
+		Amount:     decimal.NewFromInt(20000),
+		Currency:   domain.MWK,
+		Condition:  "goods delivered",
+		Expiry:     time.Now().Add(24 * time.Hour),
+	})
+	require.NoError(t, err)
+	escrowID := resp.Transaction.ID
+	require.Equal(t, domain.TransactionStatusReserved, txStatus(t, escrowID))
+
+	available, reserved := balances(t, buyerWallet.ID)
+	require.Equal(t, "30000", available.String(), "buyer available")
+	require.Equal(t, "20000", reserved.String(), "buyer reserved")
+
+	require.Error(t, sys.payments.ReleaseEscrow(ctx, escrowID, uuid.New()), "a stranger released the escrow")
+	require.NoError(t, sys.payments.ReleaseEscrow(ctx, escrowID, buyer.ID))
+	require.Equal(t, domain.TransactionStatusCompleted, txStatus(t, escrowID))
+	sellerAvailable, _ := balances(t, sellerWallet.ID)
+	require.Equal(t, "20000", sellerAvailable.String(), "seller available")
+
+	// Refund: the buyer cancels a second escrow and gets the funds back.
+	resp, err = sys.payments.CreateEscrow(ctx, &payment.EscrowRequest{
+		SenderID:   buyer.ID,
+		ReceiverID: seller.ID,
+		Amount:     decimal.NewFromInt(10000),
+		Currency:   domain.MWK,
+		Condition:  "goods delivered",
+		Expiry:     time.Now().Add(24 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.Error(t, sys.payments.RefundEscrow(ctx, resp.Transaction.ID, seller.ID), "refunded before expiry by the receiver")
+	require.NoError(t, sys.payments.RefundEscrow(ctx, resp.Transaction.ID, buyer.ID))
+	require.Equal(t, domain.TransactionStatusCancelled, txStatus(t, resp.Transaction.ID))
+	available, _ = balances(t, buyerWallet.ID)
+	require.Equal(t, "30000", available.String(), "buyer available after refund")
+}
+
+func TestDispute(t *testing.T) {
+	alice, bob := newCustomer(t, 2), newCustomer(t, 1)
+	admin := newAdmin(t)
+	aliceWallet := openWallet(t, alice, 80000)
+	bobWallet := openWallet(t, bob, 0)
+
+	tx := send(t, alice, bob, 30000)
+	paid, _ := balances(t, aliceWallet.ID)
+
+	status := alice.call(t, sys.payment.URL, http.MethodPost, "/api/v1/disputes", map[string]interface{}{
+		"transaction_id": tx.ID,
+		"reason":         "item_not_received",
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, domain.TransactionStatusDisputed, txStatus(t, tx.ID))
+
+	// Customers cannot resolve their own disputes.
+	resolve := map[string]interface{}{
+		"dispute_id": tx.ID,
+		"action":     "uphold",
+		"reason":     "receiver never delivered",
+	}
+	require.Equal(t, http.StatusForbidden, alice.call(t, sys.payment.URL, http.MethodPost, "/api/v1/admin/disputes/resolve", resolve, nil))
+
+	require.Equal(t, http.StatusOK, admin.call(t, sys.payment.URL, http.MethodPost, "/api/v1/admin/disputes/resolve", resolve, nil))
+	require.Equal(t, domain.TransactionStatusReversed, txStatus(t, tx.ID))
+
+	// The net amount moves back from the receiver to the sender.
+	refunded, _ := balances(t, aliceWallet.ID)
+	require.Equal(t, paid.Add(tx.NetAmount).String(), refunded.String(), "sender balance after reversal")
+	receiver, _ := balances(t, bobWallet.ID)
+	require.Equal(t, "0", receiver.String(), "receiver balance after reversal")
+}
+
+func TestSettlement(t *testing.T) {
+	alice, bob := newCustomer(t, 2), newCustomer(t, 1)
+	admin := newAdmin(t)
+	openWallet(t, alice, 60000)
+	openWallet(t, bob, 0)
+
+	tx := send(t, alice, bob, 15000)
+
+	// Only admins may trigger a settlement run.
+	require.Equal(t, http.StatusForbidden, alice.call(t, sys.settlement.URL, http.MethodPost, "/api/v1/settlements/process", nil, nil))
+	require.Equal(t, http.StatusOK, admin.call(t, sys.settlement.URL, http.MethodPost, "/api/v1/settlements/process", nil, nil))
+
+	// The payment service's settlement worker may have batched it first;
+	// either way it ends up in a submitted batch.
+	var settlementID uuid.NullUUID
+	eventually(t, 30*time.Second, func() bool {
+		err := sys.app.DB.QueryRow(`SELECT settlement_id FROM customer_schema.transactions WHERE id = $1`, tx.ID).Scan(&settlementID)
+		return err == nil && settlementID.Valid
+	}, "payment was never batched for settlement")
+	require.NotEqual(t, domain.TransactionStatusPendingSettlement, txStatus(t, tx.ID))
+
+	var settlementStatus domain.SettlementStatus
+	err := sys.app.DB.QueryRow(`SELECT status FROM customer_schema.settlements WHERE id = $1`, settlementID.UUID).Scan(&settlementStatus)
+	require.NoError(t, err)
+	require.NotEqual(t, domain.SettlementStatusFailed, settlementStatus)
+}